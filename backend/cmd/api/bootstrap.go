@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/bootstrap"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// runBootstrap runs the checks of POST /api/v1/admin/bootstrap from the
+// command line, before the server is started or when it will not start:
+//
+//	go run ./cmd/api bootstrap [-sample] [-json] [-strict]
+//
+// A dependency that cannot be reached is reported with the others instead
+// of stopping the run. The exit status is 1 when a check failed, or with
+// -strict warned.
+func runBootstrap(args []string) int {
+	fs := flag.NewFlagSet("bootstrap", flag.ContinueOnError)
+	sample := fs.Bool("sample", false, "analyse a sample log end to end after the checks")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	strict := fs.Bool("strict", false, "exit non-zero on warnings too")
+	timeout := fs.Duration("timeout", bootstrap.DefaultSampleTimeout, "timeout of the sample analysis")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	_ = godotenv.Load()
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load("../../.env")
+	// The report goes to stdout; the log of the connections stays out of it.
+	setupStderrLogger(slog.LevelWarn)
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	deps, closeDeps := connectBootstrapDeps(ctx, cfg)
+	defer closeDeps()
+
+	report := bootstrap.New(deps).Run(ctx, bootstrap.Options{RunSample: *sample, SampleTimeout: *timeout})
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printBootstrapReport(os.Stdout, report)
+	}
+	if report.Failed(*strict) {
+		return 1
+	}
+	return 0
+}
+
+// connectBootstrapDeps connects to every dependency of cfg, each bounded
+// by bootstrap.DefaultConnectTimeout. A connection that fails is recorded
+// in Unavailable under the name of its check.
+func connectBootstrapDeps(ctx context.Context, cfg *config.Config) (bootstrap.Deps, func()) {
+	deps := bootstrap.Deps{Config: cfg, Unavailable: map[string]error{}}
+	var closers []func()
+	connect := func(fn func(ctx context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, bootstrap.DefaultConnectTimeout)
+		defer cancel()
+		return fn(ctx)
+	}
+
+	var pg *storage.PostgresClient
+	if err := connect(func(ctx context.Context) (err error) {
+		pg, err = storage.NewPostgresClient(ctx, cfg.PostgresURL)
+		return err
+	}); err != nil {
+		deps.Unavailable[bootstrap.CheckPostgres] = err
+	} else {
+		deps.Postgres = pg
+		closers = append(closers, pg.Close)
+	}
+
+	var ch *storage.ClickHouseClient
+	if err := connect(func(ctx context.Context) (err error) {
+		ch, err = storage.NewClickHouseClient(ctx, cfg.ClickHouseURL)
+		return err
+	}); err != nil {
+		deps.Unavailable[bootstrap.CheckClickHouse] = err
+	} else {
+		deps.ClickHouse = ch
+		closers = append(closers, func() { _ = ch.Close() })
+	}
+
+	var redis *storage.RedisClient
+	if err := connect(func(ctx context.Context) (err error) {
+		redis, err = storage.NewRedisClient(ctx, cfg.RedisURL)
+		return err
+	}); err != nil {
+		deps.Unavailable[bootstrap.CheckRedis] = err
+	} else {
+		deps.Redis = redis
+		closers = append(closers, func() { _ = redis.Close() })
+	}
+
+	var objects storage.ObjectStorage
+	if err := connect(func(ctx context.Context) (err error) {
+		objects, err = storage.NewObjectStorage(ctx, objectStorageConfig(cfg))
+		return err
+	}); err != nil {
+		objects = nil
+		deps.Unavailable[bootstrap.CheckObjectStorage] = err
+	} else {
+		deps.Objects = objects
+	}
+
+	natsClient, err := streaming.NewNATSClient(cfg.NATSURL, streaming.NATSAuth{
+		CredsFile:    cfg.NATSCredsFile,
+		NKeySeedFile: cfg.NATSNKeySeedFile,
+		User:         cfg.NATSUser,
+		Password:     cfg.NATSPassword,
+	})
+	if err != nil {
+		deps.Unavailable[bootstrap.CheckNATS] = err
+	} else {
+		deps.NATS = natsClient
+		closers = append(closers, natsClient.Close)
+	}
+
+	deps.JAR = jar.NewRunner(cfg.JARPath, cfg.JARDefaultHeapMB, cfg.JARTimeoutSec)
+
+	// The pipeline needs every store; without one, the sample reports what
+	// is missing rather than failing halfway through.
+	var missing []string
+	for _, name := range []string{bootstrap.CheckPostgres, bootstrap.CheckClickHouse, bootstrap.CheckRedis, bootstrap.CheckObjectStorage, bootstrap.CheckNATS} {
+		if deps.Unavailable[name] != nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		deps.Unavailable[bootstrap.CheckSample] = errors.New("no connection to " + strings.Join(missing, ", "))
+	} else {
+		deps.Sample = newBootstrapSample(cfg, pg, ch, objects, redis, natsClient)
+	}
+
+	return deps, func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+}
+
+// newBootstrapSample creates the sample analysis of the bootstrap, run by
+// a pipeline of its own configured as the worker's.
+func newBootstrapSample(cfg *config.Config, pg *storage.PostgresClient, ch *storage.ClickHouseClient, objects storage.ObjectStorage, redis *storage.RedisClient, natsClient *streaming.NATSClient) *bootstrap.Sample {
+	pipeline := worker.NewPipeline(pg, ch, objects, redis, natsClient,
+		jar.NewRunner(cfg.JARPath, cfg.JARDefaultHeapMB, cfg.JARTimeoutSec), worker.NewAnomalyDetector(3.0))
+	pipeline.Configure(cfg)
+	purger := worker.NewJobPurger(pg, ch, redis, objects, time.Duration(cfg.TrashGraceDays)*24*time.Hour)
+	return bootstrap.NewSample(pg, ch, objects, pipeline, purger)
+}
+
+// printBootstrapReport writes a line per check, with the remediation of
+// each check that did not pass under it.
+func printBootstrapReport(w io.Writer, report *bootstrap.Report) {
+	for _, c := range report.Checks {
+		msg := strings.Join(strings.Fields(c.Message), " ")
+		fmt.Fprintf(w, "%-4s  %-17s  %s (%dms)\n", strings.ToUpper(string(c.Status)), c.Name, msg, c.LatencyMS)
+		if c.Remediation != "" {
+			fmt.Fprintf(w, "%25s-> %s\n", "", c.Remediation)
+		}
+	}
+	fmt.Fprintf(w, "\nbootstrap %s in %dms\n", report.Status, report.DurationMS)
+}
+
+func setupStderrLogger(level slog.Leveler) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
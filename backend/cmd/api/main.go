@@ -14,13 +14,28 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/ai"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/handlers"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/bootstrap"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/diagnostics"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/localauth"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tenantconfig"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/ticketing"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/usage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		os.Exit(runBootstrap(os.Args[2:]))
+	}
+
 	// Load .env file if present (development convenience).
 	_ = godotenv.Load()             // backend/.env
 	_ = godotenv.Load("../.env")    // running from backend/ -> project root .env
@@ -32,7 +47,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLogger(cfg.LogLevel)
+	// Runtime-tunable settings; the stored overrides are applied once
+	// Postgres is up.
+	settings := config.NewDynamicSettings(cfg)
+	setupLogger(settings)
 	slog.Info("starting RemedyIQ API server", "port", cfg.APIPort, "env", cfg.Environment)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -52,8 +70,41 @@ func main() {
 		os.Exit(1)
 	}
 	defer ch.Close()
+	for name, dsn := range cfg.ClickHouseClusters {
+		if err := ch.AddCluster(ctx, name, dsn); err != nil {
+			slog.Error("failed to connect to ClickHouse cluster", "cluster", name, "error", err)
+			os.Exit(1)
+		}
+	}
 
-	natsClient, err := streaming.NewNATSClient(cfg.NATSURL)
+	// Read replicas are optional: without them every read goes to the
+	// primaries.
+	replicaCfg := storage.ReplicaConfig{
+		FailureThreshold: cfg.ReplicaFailureThreshold,
+		Cooldown:         time.Duration(cfg.ReplicaCooldownSec) * time.Second,
+		MaxStaleness:     time.Duration(cfg.ReplicaMaxStalenessMS) * time.Millisecond,
+	}
+	if cfg.PostgresReplicaURL != "" {
+		if err := pg.SetReadReplica(ctx, cfg.PostgresReplicaURL, replicaCfg); err != nil {
+			slog.Warn("failed to connect to the PostgreSQL read replica, reading from the primary", "error", err)
+		} else {
+			go pg.WatchReadReplica(ctx)
+		}
+	}
+	if cfg.ClickHouseReplicaURL != "" {
+		if err := ch.SetReadReplica(ctx, cfg.ClickHouseReplicaURL, replicaCfg); err != nil {
+			slog.Warn("failed to connect to the ClickHouse read replica, reading from the primary", "error", err)
+		} else {
+			go ch.WatchReadReplica(ctx)
+		}
+	}
+
+	natsClient, err := streaming.NewNATSClient(cfg.NATSURL, streaming.NATSAuth{
+		CredsFile:    cfg.NATSCredsFile,
+		NKeySeedFile: cfg.NATSNKeySeedFile,
+		User:         cfg.NATSUser,
+		Password:     cfg.NATSPassword,
+	})
 	if err != nil {
 		slog.Error("failed to connect to NATS", "error", err)
 		os.Exit(1)
@@ -71,11 +122,56 @@ func main() {
 		os.Exit(1)
 	}
 	defer redis.Close()
+	cachePolicy := storage.DefaultCachePolicy()
+	cachePolicy.TenantBudgetBytes = int64(cfg.RedisTenantCacheBudgetMB) << 20
+	cachePolicy.CompressMinBytes = cfg.RedisCompressMinKB << 10
+	redis.SetCachePolicy(cachePolicy)
+	ch.SetSearchLimits(storage.SearchLimits{
+		MaxRowsToRead:  uint64(cfg.ClickHouseSearchMaxRows),
+		MaxMemoryBytes: uint64(cfg.ClickHouseSearchMaxMemMB) << 20,
+	})
+	if cfg.ClickHouseResultCache {
+		ch.SetResultCache(redis, pg, storage.ResultCacheConfig{
+			TTL:           time.Duration(cfg.ClickHouseResultTTLSec) * time.Second,
+			MaxEntryBytes: cfg.ClickHouseResultMaxKB << 10,
+		})
+	}
+
+	// Apply the stored dynamic settings and follow their changes.
+	if err := settings.Reload(ctx, pg); err != nil {
+		slog.Warn("failed to load dynamic settings, using defaults", "error", err)
+	}
+	settingsChanges, err := redis.SubscribeSettingsChanges(ctx)
+	if err != nil {
+		slog.Warn("failed to subscribe to settings changes, polling only", "error", err)
+	}
+	go settings.Watch(ctx, pg, settingsChanges, time.Duration(cfg.SettingsPollSec)*time.Second)
 
-	// S3 is non-critical at startup — log and continue if unavailable.
-	s3Client, err := storage.NewS3Client(ctx, cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3UseSSL, cfg.S3SkipBucketVerification)
+	// Object storage is non-critical at startup — log and continue if unavailable.
+	objectStore, err := storage.NewObjectStorage(ctx, objectStorageConfig(cfg))
+	objectStoreErr := err
 	if err != nil {
-		slog.Warn("S3 client initialization failed; file uploads will not work", "error", err)
+		slog.Warn("object storage initialization failed; file uploads will not work", "backend", cfg.StorageBackend, "error", err)
+	}
+
+	// Tenants pinned to a data residency region keep their objects in the
+	// region's bucket and their entries in its ClickHouse, registered as a
+	// cluster before the routes to it are loaded.
+	regions, err := storage.NewRegions(pg, ch, objectStore, cfg.RegionConfigs(), time.Duration(cfg.ClickHouseRouteSyncSec)*time.Second)
+	if err != nil {
+		slog.Error("failed to configure storage regions", "error", err)
+		os.Exit(1)
+	}
+	if len(cfg.StorageRegions) > 0 {
+		objectStore = storage.NewRegionalObjectStorage(regions)
+		go regions.WatchHealth(ctx, time.Duration(cfg.StorageRegionHealthSec)*time.Second)
+	}
+	if err := ch.RefreshRoutes(ctx, pg); err != nil {
+		slog.Error("failed to load ClickHouse routes", "error", err)
+		os.Exit(1)
+	}
+	if len(cfg.ClickHouseClusters) > 0 || len(cfg.StorageRegions) > 0 {
+		go ch.WatchRoutes(ctx, pg, time.Duration(cfg.ClickHouseRouteSyncSec)*time.Second)
 	}
 
 	bleveManager, err := search.NewBleveManager(cfg.BlevePath)
@@ -85,8 +181,30 @@ func main() {
 	}
 	defer bleveManager.Close()
 
+	// --- Usage accounting ---
+	// Events are written in the background and flushed on shutdown.
+	usageRecorder := usage.NewRecorder(pg, usage.DefaultBufferSize, usage.DefaultFlushInterval)
+	usageCtx, usageCancel := context.WithCancel(context.Background())
+	usageDone := make(chan struct{})
+	go func() {
+		usageRecorder.Run(usageCtx)
+		close(usageDone)
+	}()
+
+	// --- Job event log ---
+	// Events are written in the background and flushed on shutdown.
+	jobEvents := jobevents.NewAppender(pg, domain.JobEventSourceAPI, jobevents.DefaultBufferSize, cfg.JobEventsMaxPerJob, jobevents.DefaultFlushInterval)
+	jobEventsCtx, jobEventsCancel := context.WithCancel(context.Background())
+	jobEventsDone := make(chan struct{})
+	go func() {
+		jobEvents.Run(jobEventsCtx)
+		close(jobEventsDone)
+	}()
+
 	// --- WebSocket hub ---
 	wsHub := streaming.NewHub()
+	wsHub.SetDashboardSource(handlers.NewDashboardStream(pg, ch, redis), streaming.DefaultDashboardParallelism)
+	wsHub.SetJobStateSource(handlers.NewJobStates(pg))
 	go wsHub.Run()
 
 	// --- Build handlers ---
@@ -96,25 +214,89 @@ func main() {
 		func(ctx context.Context) error { return natsClient.Ping() },
 		redis.Ping,
 	)
+	if cfg.ClickHouseResultCache {
+		healthHandler.SetResultCacheStats(ch.ResultCacheStats)
+	}
 
-	uploadHandler := handlers.NewUploadHandler(pg, s3Client)
+	uploadHandler := handlers.NewUploadHandler(pg, objectStore, usageRecorder)
 	fileHandlers := handlers.NewFileHandlers(pg)
-	analysisHandlers := handlers.NewAnalysisHandlers(pg, natsClient)
+	priorityPolicy, err := worker.ParsePriorityPolicy(cfg.JobPriorityPolicy)
+	if err != nil {
+		slog.Error("invalid job priority policy", "error", err)
+		os.Exit(1)
+	}
+	queueEstimator := worker.NewQueueEstimator(pg, cfg.WorkerMaxConcurrentJobs, priorityPolicy,
+		time.Duration(cfg.JobMaxQueueWaitSec)*time.Second)
+	diagnosticsAssembler := diagnostics.NewAssembler(pg, ch, ch, redis, objectStore, settings, diagnostics.Options{
+		MaxBytes:           cfg.DiagnosticsMaxMB << 20,
+		SlowQueryThreshold: time.Duration(cfg.DiagnosticsSlowQueryMS) * time.Millisecond,
+		Config: map[string]any{
+			"JAR_PATH":                   cfg.JARPath,
+			"JAR_DEFAULT_HEAP_MB":        cfg.JARDefaultHeapMB,
+			"JAR_TIMEOUT_SEC":            cfg.JARTimeoutSec,
+			"JAR_MAX_SECTION_ROWS":       cfg.JARMaxSectionRows,
+			"JAR_STRICT_PARSE":           cfg.JARStrictParse,
+			"JAR_OUTPUT_FORMAT":          cfg.JAROutputFormat,
+			"JAR_STORE_OUTPUT":           cfg.JARStoreOutput,
+			"JOB_CHECKPOINTS":            cfg.JobCheckpoints,
+			"WORKER_HEAP_BUDGET_MB":      cfg.WorkerHeapBudgetMB,
+			"INLINE_ANALYSIS_MAX_KB":     cfg.InlineAnalysisMaxKB,
+			"INLINE_ANALYSIS_HEAP_MB":    cfg.InlineHeapMB,
+			"DATA_QUALITY_MINOR_PCT":     cfg.DataQualityMinorPct,
+			"DATA_QUALITY_MAJOR_PCT":     cfg.DataQualityMajorPct,
+			"JOB_EVENTS_MAX_PER_JOB":     cfg.JobEventsMaxPerJob,
+			"CLICKHOUSE_URL":             cfg.ClickHouseURL,
+			"CLICKHOUSE_SEARCH_MAX_ROWS": cfg.ClickHouseSearchMaxRows,
+		},
+	})
+
+	analysisHandlers := handlers.NewAnalysisHandlers(pg, natsClient, queueEstimator, jobEvents, cfg.AdminUserIDs)
+	analysisHandlers.SetJobEstimator(worker.NewJobEstimator(pg, queueEstimator))
+	analysisHandlers.SetCancelFlags(redis)
+
+	// Small files are analysed within the request by a pipeline of their
+	// own, whose JARs run with a small heap. The inline deadline bounds
+	// them in place of the JAR timeout.
+	if cfg.InlineAnalysisMaxKB > 0 && objectStore != nil {
+		newInlineJAR := func(path string) worker.JARRunner {
+			return jar.NewRunner(path, cfg.InlineHeapMB, cfg.InlineTimeoutSec)
+		}
+		inlinePipeline := worker.NewPipeline(pg, ch, objectStore, redis, natsClient, newInlineJAR(cfg.JARPath), worker.NewAnomalyDetector(3.0))
+		inlinePipeline.SetDynamic(settings)
+		inlinePipeline.SetLegacyRunners(worker.LegacyRunners(cfg.JARLegacyPaths, newInlineJAR))
+		inlinePipeline.SetThresholdEvaluator(worker.NewThresholdEvaluator(pg, ch, natsClient))
+		inlinePipeline.Configure(cfg)
+		inlinePipeline.SetUsageRecorder(usageRecorder)
+		inlinePipeline.SetJobEvents(jobEvents)
+		if len(cfg.StorageRegions) > 0 {
+			inlinePipeline.SetRegions(regions)
+		}
+		analysisHandlers.SetInlineAnalyzer(worker.NewInlineRunner(inlinePipeline, worker.InlineConfig{
+			MaxBytes:      int64(cfg.InlineAnalysisMaxKB) << 10,
+			Timeout:       time.Duration(cfg.InlineTimeoutSec) * time.Second,
+			MaxConcurrent: cfg.InlineMaxConcurrent,
+			MaxPerTenant:  cfg.InlineMaxPerTenant,
+		}), cfg.InlineAnalysisAuto)
+	}
 	dashboardHandler := handlers.NewDashboardHandler(pg, ch, redis)
 	streamHandler := handlers.NewStreamHandler(wsHub, []string{"*"})
+	sseHandler := handlers.NewSSEHandler(wsHub)
 
 	reportHandler := handlers.NewReportHandler(pg, redis)
 
 	searchLogsHandler := handlers.NewSearchLogsHandler(ch, bleveManager, redis, pg)
+	searchLogsHandler.SetDynamic(settings)
 	autocompleteHandler := handlers.NewAutocompleteHandler(ch)
+	vocabularyHandler := handlers.NewVocabularyHandler(pg)
 	entryHandler := handlers.NewEntryHandler(ch)
 	contextHandler := handlers.NewContextHandler(ch)
 	exportHandler := handlers.NewExportHandler(ch)
-	traceHandler := handlers.NewTraceHandler(ch, redis)
-	waterfallHandler := handlers.NewWaterfallHandler(ch, redis)
+	traceHandler := handlers.NewTraceHandler(ch, redis, cfg.TraceMaxEntries)
+	waterfallHandler := handlers.NewWaterfallHandler(ch, redis, pg)
 	transactionSearchHandler := handlers.NewTransactionSearchHandler(ch)
 	recentTracesHandler := handlers.NewRecentTracesHandler(redis)
 	exportTraceHandler := handlers.NewExportTraceHandler(ch)
+	traceDiffHandler := handlers.NewTraceDiffHandler(pg, ch)
 
 	aiRegistry := ai.NewRegistry()
 	aiRouter := ai.NewRouter()
@@ -127,30 +309,135 @@ func main() {
 		slog.Warn("Gemini client initialization failed; AI streaming will not work", "error", err)
 	}
 
-	aiStreamHandler := handlers.NewAIStreamHandler(geminiClient, aiRegistry, aiRouter, pg, ch, redis)
+	aiStreamHandler := handlers.NewAIStreamHandler(geminiClient, aiRegistry, aiRouter, pg, ch, redis, usageRecorder)
 	conversationsHandler := handlers.NewConversationsHandler(pg)
 	conversationDetailHandler := handlers.NewConversationDetailHandler(pg)
 
 	savedSearchHandler := handlers.NewSavedSearchHandler(pg)
+	thresholdHandlers := handlers.NewThresholdRuleHandlers(pg)
+	ingestionFilterHandlers := handlers.NewIngestionFilterHandlers(pg, ch)
+
+	var ticketingKeyring *ticketing.Keyring
+	if cfg.TicketingEncryptionKey != "" {
+		ticketingKeyring, err = ticketing.NewKeyring(cfg.TicketingEncryptionKey)
+		if err != nil {
+			slog.Error("invalid TICKETING_ENCRYPTION_KEY", "error", err)
+			os.Exit(1)
+		}
+	}
+	analysisLinkHandlers := handlers.NewAnalysisLinkHandlers(pg, ticketingKeyring, nil)
+	incidentGroupHandlers := handlers.NewIncidentGroupHandlers(pg)
+	backgroundTaskHandlers := handlers.NewBackgroundTaskHandlers(pg)
+
+	investigationHandlers := handlers.NewInvestigationHandlers(pg, wsHub)
+	workspaceHandlers := handlers.NewWorkspaceHandlers(pg, ch)
 	deleteSavedSearchHandler := handlers.NewDeleteSavedSearchHandler(pg)
 	searchHistoryHandler := handlers.NewSearchHistoryHandler(pg)
+	searchExportHandlers := handlers.NewSearchExportHandlers(pg, natsClient, objectStore,
+		time.Duration(cfg.ExportURLExpiryMin)*time.Minute,
+		time.Duration(cfg.ExportRetentionDays)*24*time.Hour,
+	)
+	comparisonHandlers := handlers.NewComparisonHandlers(pg, ch, natsClient,
+		time.Duration(cfg.ExportRetentionDays)*24*time.Hour,
+	)
+	uploadLimiter := middleware.NewUploadLimiter(redis, cfg.UploadMaxConcurrent,
+		middleware.NewBandwidthShaper(int64(cfg.UploadTenantBytesPerSec), int64(cfg.UploadTenantBurstBytes),
+			int64(cfg.UploadReplicaBytesPerSec()), int64(cfg.UploadGlobalBurstBytes)))
+	usageHandlers := handlers.NewUsageHandlers(pg, redis, uploadLimiter, cfg.AdminUserIDs)
+	tenantRegionHandlers := handlers.NewTenantRegionHandlers(pg, regions)
+	tenantAdminHandlers := handlers.NewTenantAdminHandlers(pg, ch)
+	tenantExportHandlers := handlers.NewTenantExportHandlers(pg, natsClient, objectStore,
+		time.Duration(cfg.ExportURLExpiryMin)*time.Minute,
+		time.Duration(cfg.ExportRetentionDays)*24*time.Hour,
+	)
+	tenantConfigHandlers := handlers.NewTenantConfigHandlers(pg, tenantconfig.NewManager(tenantconfig.DefaultAdapters(pg)...))
+	supportAccessHandlers := handlers.NewSupportAccessHandlers(pg)
+	jobPurger := worker.NewJobPurger(pg, ch, redis, objectStore, time.Duration(cfg.TrashGraceDays)*24*time.Hour)
+	trashHandlers := handlers.NewTrashHandlers(pg, jobPurger, cfg.AdminUserIDs)
+	preferencesHandlers := handlers.NewPreferencesHandlers(pg)
+
+	bootstrapDeps := bootstrap.Deps{
+		Postgres:   pg,
+		ClickHouse: ch,
+		Redis:      redis,
+		NATS:       natsClient,
+		JAR:        jar.NewRunner(cfg.JARPath, cfg.JARDefaultHeapMB, cfg.JARTimeoutSec),
+		Config:     cfg,
+	}
+	if objectStoreErr != nil {
+		bootstrapDeps.Unavailable = map[string]error{
+			bootstrap.CheckObjectStorage: objectStoreErr,
+			bootstrap.CheckSample:        objectStoreErr,
+		}
+	} else {
+		bootstrapDeps.Objects = objectStore
+		bootstrapDeps.Sample = newBootstrapSample(cfg, pg, ch, objectStore, redis, natsClient)
+	}
+
+	var rateLimiter *middleware.RateLimiter
+	if cfg.RateLimitEnabled {
+		rateLimiter = middleware.NewDynamicRateLimiter(redis, settings)
+	}
+
+	queryLimiter := middleware.NewQueryLimiter(redis, ch, cfg.QueryMaxConcurrent,
+		time.Duration(cfg.QueryQueueWaitMS)*time.Millisecond)
+	queryHandlers := handlers.NewQueryHandlers(redis, ch)
+	idempotency := middleware.NewIdempotency(pg,
+		time.Duration(cfg.IdempotencyTTLHours)*time.Hour,
+		time.Duration(cfg.IdempotencyWaitMS)*time.Millisecond)
+
+	// --- Local auth (AUTH_PROVIDER=local) ---
+	// Air-gapped deployments sign users in against Postgres instead of Clerk.
+	var authProvider middleware.AuthProvider
+	var loginHandler http.Handler
+	if cfg.AuthProvider == "local" {
+		issuer, err := localauth.NewIssuer(cfg.LocalAuthSigningKeys, time.Duration(cfg.LocalAuthTokenTTLMin)*time.Minute)
+		if err != nil {
+			slog.Error("failed to initialize local auth", "error", err)
+			os.Exit(1)
+		}
+		created, err := localauth.Bootstrap(ctx, pg, cfg.LocalAdminEmail, cfg.LocalAdminPassword)
+		if err != nil {
+			slog.Error("failed to bootstrap local admin", "error", err)
+			os.Exit(1)
+		}
+		if created {
+			slog.Info("created local admin", "email", cfg.LocalAdminEmail)
+		}
+		authProvider = issuer
+		loginHandler = handlers.NewLoginHandler(pg, issuer)
+		slog.Warn("local auth provider enabled; Clerk is not used")
+	}
 
 	// --- Build router ---
+	// Validated by config.Load; empty leaves v1 undeprecated.
+	v1Sunset, _ := time.Parse(time.DateOnly, cfg.APIV1Sunset)
 	router := api.NewRouter(api.RouterConfig{
 		AllowedOrigins:            []string{"*"},
 		DevMode:                   cfg.IsDevelopment(),
 		ClerkSecretKey:            cfg.ClerkSecretKey,
+		AuthProvider:              authProvider,
+		V1Sunset:                  v1Sunset,
+		LoginHandler:              loginHandler,
 		HealthHandler:             healthHandler,
 		UploadFileHandler:         uploadHandler,
 		ListFilesHandler:          fileHandlers.ListFiles(),
 		CreateAnalysisHandler:     analysisHandlers.CreateAnalysis(),
+		EstimateAnalysisHandler:   analysisHandlers.EstimateAnalysis(),
+		CancelAnalysisHandler:     analysisHandlers.CancelAnalysis(),
 		ListAnalysesHandler:       analysisHandlers.ListAnalyses(),
 		GetAnalysisHandler:        analysisHandlers.GetAnalysis(),
+		JobEventsHandler:          analysisHandlers.JobEvents(),
 		GetDashboardHandler:       dashboardHandler,
 		AggregatesHandler:         handlers.NewAggregatesHandler(pg, ch, redis),
 		ExceptionsHandler:         handlers.NewExceptionsHandler(pg, ch, redis),
+		ErrorHeatmapHandler:       handlers.NewErrorHeatmapHandler(pg, ch, redis),
 		GapsHandler:               handlers.NewGapsHandler(pg, ch, redis),
 		ThreadsHandler:            handlers.NewThreadsHandler(pg, ch, redis),
+		ThreadTimelineHandler:     handlers.NewThreadTimelineHandler(pg, ch),
+		ErrorOnsetHandler:         handlers.NewErrorOnsetHandler(pg, ch),
+		SQLTableHandler:           handlers.NewSQLTableHandler(pg, ch, redis),
+		JARReportHandler:          handlers.NewJARReportHandler(pg, redis),
 		FiltersHandler:            handlers.NewFiltersHandler(pg, ch, redis),
 		QueuedCallsHandler:        handlers.NewQueuedCallsHandler(pg, ch, redis),
 		LoggingActivityHandler:    handlers.NewLoggingActivityHandler(pg, ch, redis),
@@ -158,25 +445,117 @@ func main() {
 		DelayedEscalationsHandler: handlers.NewDelayedEscalationsHandler(pg, ch),
 		GenerateReportHandler:     reportHandler,
 		WSHandler:                 streamHandler,
+		SSEHandler:                sseHandler,
 		SearchLogsHandler:         searchLogsHandler,
 		AutocompleteHandler:       autocompleteHandler,
+		VocabularyHandler:         vocabularyHandler,
+		ResolveEntryHandler:       handlers.NewEntryResolveHandler(ch),
 		GetLogEntryHandler:        entryHandler,
 		GetEntryContextHandler:    contextHandler,
+		ExplainEntryHandler:       handlers.NewEntryExplainHandler(pg, ch),
 		ExportHandler:             exportHandler,
+		CreateSearchExportHandler: searchExportHandlers.CreateExport(),
+		ListSearchExportsHandler:  searchExportHandlers.ListExports(),
+		GetSearchExportHandler:    searchExportHandlers.GetExport(),
+		DownloadExportHandler:     searchExportHandlers.DownloadExport(),
+		ExportProvenanceHandler:   searchExportHandlers.ExportProvenance(),
+		RegenerateExportHandler:   searchExportHandlers.RegenerateExport(),
 		SavedSearchHandler:        savedSearchHandler,
 		DeleteSavedSearchHandler:  deleteSavedSearchHandler,
 		SearchHistoryHandler:      searchHistoryHandler,
+		ListViolationsHandler:     thresholdHandlers.ListViolations(),
 		GetTraceHandler:           traceHandler,
 		GetWaterfallHandler:       waterfallHandler,
 		SearchTransactionsHandler: transactionSearchHandler,
 		GetRecentTracesHandler:    recentTracesHandler,
 		ExportTraceHandler:        exportTraceHandler,
+		TraceDiffHandler:          traceDiffHandler,
 		TraceAIHandler:            traceAIHandler,
 		QueryAIHandler:            aiHandler,
 		ListSkillsHandler:         listSkillsHandler,
 		AIStreamHandler:           aiStreamHandler,
 		ConversationsHandler:      conversationsHandler,
 		ConversationDetailHandler: conversationDetailHandler,
+
+		CompareAnalysesHandler:        comparisonHandlers.Compare(),
+		CreateComparisonExportHandler: comparisonHandlers.CreateExport(),
+		CaptureBaselineHandler:        comparisonHandlers.CaptureBaseline(),
+
+		DeleteAnalysisHandler:  trashHandlers.DeleteAnalysis(),
+		TrashHandler:           trashHandlers.ListTrash(),
+		RestoreAnalysisHandler: trashHandlers.RestoreAnalysis(),
+		JobGuard:               handlers.NewJobGuard(pg).RequireLiveJob,
+		RateLimiter:            rateLimiter,
+		QueryLimiter:           queryLimiter,
+		UploadLimiter:          uploadLimiter,
+		Idempotency:            idempotency,
+		SupportAccess:          middleware.NewSupportAccessMiddleware(pg, cfg.SupportUserIDs),
+
+		ListThresholdRulesHandler:  thresholdHandlers.ListRules(),
+		CreateThresholdRuleHandler: thresholdHandlers.CreateRule(),
+		UpdateThresholdRuleHandler: thresholdHandlers.UpdateRule(),
+		DeleteThresholdRuleHandler: thresholdHandlers.DeleteRule(),
+		DigestSubscriptionHandler:  handlers.NewDigestSubscriptionHandler(pg),
+
+		PreferencesHandler:         preferencesHandlers.Preferences(),
+		PreferencesDefaultsHandler: preferencesHandlers.Defaults(),
+
+		ListIngestionFiltersHandler:  ingestionFilterHandlers.ListRules(),
+		CreateIngestionFilterHandler: ingestionFilterHandlers.CreateRule(),
+		DryRunIngestionFilterHandler: ingestionFilterHandlers.DryRun(),
+		UpdateIngestionFilterHandler: ingestionFilterHandlers.UpdateRule(),
+		DeleteIngestionFilterHandler: ingestionFilterHandlers.DeleteRule(),
+		ListQueriesHandler:           queryHandlers.ListQueries(),
+		KillQueryHandler:             queryHandlers.KillQuery(),
+
+		ListAnalysisLinksHandler:          analysisLinkHandlers.ListLinks(),
+		CreateAnalysisLinkHandler:         analysisLinkHandlers.CreateLink(),
+		DeleteAnalysisLinkHandler:         analysisLinkHandlers.DeleteLink(),
+		GetTicketingIntegrationHandler:    analysisLinkHandlers.GetIntegration(),
+		PutTicketingIntegrationHandler:    analysisLinkHandlers.PutIntegration(),
+		DeleteTicketingIntegrationHandler: analysisLinkHandlers.DeleteIntegration(),
+
+		CreateIncidentGroupHandler: incidentGroupHandlers.CreateGroup(),
+		GetIncidentGroupHandler:    incidentGroupHandlers.GetGroup(),
+		SetJobIncidentGroupHandler: incidentGroupHandlers.SetJobGroup(),
+
+		TenantUsageHandler: usageHandlers.TenantUsage(),
+
+		CreateSupportGrantHandler:    supportAccessHandlers.CreateGrant(),
+		ListSupportGrantsHandler:     supportAccessHandlers.ListGrants(),
+		RevokeSupportGrantHandler:    supportAccessHandlers.RevokeGrant(),
+		SupportAccessActivityHandler: supportAccessHandlers.ListActivity(),
+
+		CreateTenantExportHandler:   tenantExportHandlers.CreateExport(),
+		GetTenantExportHandler:      tenantExportHandlers.GetExport(),
+		DownloadTenantExportHandler: tenantExportHandlers.DownloadExport(),
+		TenantConfigExportHandler:   tenantConfigHandlers.Export(),
+		TenantConfigImportHandler:   tenantConfigHandlers.Import(),
+		SQLRedactionHandler:         handlers.NewSQLRedactionHandler(pg),
+
+		AdminUserIDs:           cfg.AdminUserIDs,
+		TenantRetentionHandler: handlers.NewTenantRetentionHandler(pg, ch),
+		HealthProfileHandler:   handlers.NewHealthProfileHandler(pg),
+		AllTenantsUsageHandler: usageHandlers.AllTenantsUsage(),
+		FixtureCaptureHandler:  handlers.NewFixtureCaptureHandler(pg, objectStore, jar.NewRunner(cfg.JARPath, cfg.JARDefaultHeapMB, cfg.JARTimeoutSec)),
+		DiagnosticsHandler:     handlers.NewDiagnosticsHandler(diagnosticsAssembler, jobEvents),
+		QueueStatusHandler:     handlers.NewQueueStatusHandler(pg, natsClient, redis),
+		SettingsHandler:        handlers.NewSettingsHandler(pg, settings, redis),
+		TenantRegionHandler:    tenantRegionHandlers.SetRegion(),
+		RegionsHandler:         tenantRegionHandlers.ListRegions(),
+		ListTasksHandler:       backgroundTaskHandlers.ListTasks(),
+		RunTaskNowHandler:      backgroundTaskHandlers.RunNow(),
+		CreateTenantHandler:    tenantAdminHandlers.CreateTenant(),
+		GetTenantHandler:       tenantAdminHandlers.GetTenant(),
+		ConvertSandboxHandler:  tenantAdminHandlers.ConvertSandbox(),
+		BootstrapHandler:       handlers.NewBootstrapHandler(bootstrap.New(bootstrapDeps)),
+
+		APILegendHandler: handlers.NewAPILegendHandler(pg),
+
+		UpdateInvestigationHandler:  investigationHandlers.UpdateInvestigation(),
+		InvestigationHistoryHandler: investigationHandlers.ListHistory(),
+		WorkspacesHandler:           workspaceHandlers.Workspaces(),
+		WorkspaceHandler:            workspaceHandlers.Workspace(),
 	})
 
 	// --- Start HTTP server ---
@@ -213,23 +592,38 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("HTTP server shutdown error", "error", err)
 	}
+	usageCancel()
+	<-usageDone
+	jobEventsCancel()
+	<-jobEventsDone
 
 	slog.Info("RemedyIQ API server stopped")
 }
 
-func setupLogger(level string) {
-	var logLevel slog.Level
-	switch level {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
+// objectStorageConfig returns the object storage selected by cfg.
+func objectStorageConfig(cfg *config.Config) storage.ObjectStorageConfig {
+	return storage.ObjectStorageConfig{
+		Backend:                  cfg.StorageBackend,
+		S3Endpoint:               cfg.S3Endpoint,
+		S3AccessKey:              cfg.S3AccessKey,
+		S3SecretKey:              cfg.S3SecretKey,
+		S3Bucket:                 cfg.S3Bucket,
+		S3UseSSL:                 cfg.S3UseSSL,
+		S3SkipBucketVerification: cfg.S3SkipBucketVerification,
+		AzureEndpoint:            cfg.AzureStorageEndpoint,
+		AzureAccount:             cfg.AzureStorageAccount,
+		AzureAccountKey:          cfg.AzureStorageKey,
+		AzureContainer:           cfg.AzureStorageContainer,
+		GCSEndpoint:              cfg.GCSEndpoint,
+		GCSAccessKey:             cfg.GCSAccessKey,
+		GCSSecretKey:             cfg.GCSSecretKey,
+		GCSBucket:                cfg.GCSBucket,
+		FilesystemRoot:           cfg.StorageFSRoot,
 	}
+}
+
+func setupLogger(level slog.Leveler) {
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
+		Level: level,
 	})))
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -13,11 +14,59 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/notify"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tasks"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/ticketing"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/usage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
 )
 
+const (
+	// exportTimeout bounds a single search export end to end.
+	exportTimeout = 30 * time.Minute
+
+	// exportCleanupInterval is how often expired export objects are removed.
+	exportCleanupInterval = time.Hour
+
+	// digestInterval is how often subscriptions are checked for due digests.
+	digestInterval = 5 * time.Minute
+
+	// retentionInterval is how often tenant plans are reconciled with the
+	// retention class of their stored log entries.
+	retentionInterval = time.Hour
+
+	// usageReconcileSchedule is when recorded usage is reconciled with the
+	// files, jobs and AI answers it was measured on: nightly, in UTC.
+	usageReconcileSchedule = "0 2 * * *"
+
+	// idempotencyCleanupInterval is how often expired idempotency keys are
+	// deleted.
+	idempotencyCleanupInterval = time.Hour
+
+	// trashPurgeInterval is how often analyses past their trash grace
+	// period are purged.
+	trashPurgeInterval = time.Hour
+
+	// sandboxCleanupInterval is how often expired sandbox tenants are
+	// purged and archived.
+	sandboxCleanupInterval = time.Hour
+
+	// ticketSyncInterval is how often the status of analyses' Jira and
+	// ServiceNow links is refreshed.
+	ticketSyncInterval = 15 * time.Minute
+
+	// costModelFitInterval is how often the tenants' job cost models are
+	// refitted on their completed jobs.
+	costModelFitInterval = time.Hour
+
+	// progressMinInterval is how often an unchanged job progress update is
+	// re-published to keep idle progress bars alive.
+	progressMinInterval = 2 * time.Second
+)
+
 func main() {
 	// Load .env file if present (development convenience).
 	_ = godotenv.Load()             // backend/.env
@@ -30,7 +79,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLogger(cfg.LogLevel)
+	// Runtime-tunable settings; the stored overrides are applied once
+	// Postgres is up.
+	settings := config.NewDynamicSettings(cfg)
+	setupLogger(settings)
 	slog.Info("starting RemedyIQ Worker", "env", cfg.Environment)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -50,8 +102,41 @@ func main() {
 		os.Exit(1)
 	}
 	defer ch.Close()
+	for name, dsn := range cfg.ClickHouseClusters {
+		if err := ch.AddCluster(ctx, name, dsn); err != nil {
+			slog.Error("failed to connect to ClickHouse cluster", "cluster", name, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Read replicas are optional: without them every read goes to the
+	// primaries.
+	replicaCfg := storage.ReplicaConfig{
+		FailureThreshold: cfg.ReplicaFailureThreshold,
+		Cooldown:         time.Duration(cfg.ReplicaCooldownSec) * time.Second,
+		MaxStaleness:     time.Duration(cfg.ReplicaMaxStalenessMS) * time.Millisecond,
+	}
+	if cfg.PostgresReplicaURL != "" {
+		if err := pg.SetReadReplica(ctx, cfg.PostgresReplicaURL, replicaCfg); err != nil {
+			slog.Warn("failed to connect to the PostgreSQL read replica, reading from the primary", "error", err)
+		} else {
+			go pg.WatchReadReplica(ctx)
+		}
+	}
+	if cfg.ClickHouseReplicaURL != "" {
+		if err := ch.SetReadReplica(ctx, cfg.ClickHouseReplicaURL, replicaCfg); err != nil {
+			slog.Warn("failed to connect to the ClickHouse read replica, reading from the primary", "error", err)
+		} else {
+			go ch.WatchReadReplica(ctx)
+		}
+	}
 
-	natsClient, err := streaming.NewNATSClient(cfg.NATSURL)
+	natsClient, err := streaming.NewNATSClient(cfg.NATSURL, streaming.NATSAuth{
+		CredsFile:    cfg.NATSCredsFile,
+		NKeySeedFile: cfg.NATSNKeySeedFile,
+		User:         cfg.NATSUser,
+		Password:     cfg.NATSPassword,
+	})
 	if err != nil {
 		slog.Error("failed to connect to NATS", "error", err)
 		os.Exit(1)
@@ -69,46 +154,328 @@ func main() {
 		os.Exit(1)
 	}
 	defer redis.Close()
+	cachePolicy := storage.DefaultCachePolicy()
+	cachePolicy.TenantBudgetBytes = int64(cfg.RedisTenantCacheBudgetMB) << 20
+	cachePolicy.CompressMinBytes = cfg.RedisCompressMinKB << 10
+	redis.SetCachePolicy(cachePolicy)
+
+	// Apply the stored dynamic settings and follow their changes.
+	if err := settings.Reload(ctx, pg); err != nil {
+		slog.Warn("failed to load dynamic settings, using defaults", "error", err)
+	}
+	settingsChanges, err := redis.SubscribeSettingsChanges(ctx)
+	if err != nil {
+		slog.Warn("failed to subscribe to settings changes, polling only", "error", err)
+	}
+	go settings.Watch(ctx, pg, settingsChanges, time.Duration(cfg.SettingsPollSec)*time.Second)
 
-	s3Client, err := storage.NewS3Client(ctx, cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3UseSSL, cfg.S3SkipBucketVerification)
+	objectStore, err := storage.NewObjectStorage(ctx, storage.ObjectStorageConfig{
+		Backend:                  cfg.StorageBackend,
+		S3Endpoint:               cfg.S3Endpoint,
+		S3AccessKey:              cfg.S3AccessKey,
+		S3SecretKey:              cfg.S3SecretKey,
+		S3Bucket:                 cfg.S3Bucket,
+		S3UseSSL:                 cfg.S3UseSSL,
+		S3SkipBucketVerification: cfg.S3SkipBucketVerification,
+		AzureEndpoint:            cfg.AzureStorageEndpoint,
+		AzureAccount:             cfg.AzureStorageAccount,
+		AzureAccountKey:          cfg.AzureStorageKey,
+		AzureContainer:           cfg.AzureStorageContainer,
+		GCSEndpoint:              cfg.GCSEndpoint,
+		GCSAccessKey:             cfg.GCSAccessKey,
+		GCSSecretKey:             cfg.GCSSecretKey,
+		GCSBucket:                cfg.GCSBucket,
+		FilesystemRoot:           cfg.StorageFSRoot,
+	})
 	if err != nil {
-		slog.Error("failed to connect to S3/MinIO", "error", err)
+		slog.Error("failed to connect to object storage", "backend", cfg.StorageBackend, "error", err)
 		os.Exit(1)
 	}
 
+	// Tenants pinned to a data residency region keep their objects in the
+	// region's bucket and their entries in its ClickHouse, registered as a
+	// cluster before the routes to it are loaded.
+	regions, err := storage.NewRegions(pg, ch, objectStore, cfg.RegionConfigs(), time.Duration(cfg.ClickHouseRouteSyncSec)*time.Second)
+	if err != nil {
+		slog.Error("failed to configure storage regions", "error", err)
+		os.Exit(1)
+	}
+	if len(cfg.StorageRegions) > 0 {
+		objectStore = storage.NewRegionalObjectStorage(regions)
+		go regions.WatchHealth(ctx, time.Duration(cfg.StorageRegionHealthSec)*time.Second)
+	}
+	if err := ch.RefreshRoutes(ctx, pg); err != nil {
+		slog.Error("failed to load ClickHouse routes", "error", err)
+		os.Exit(1)
+	}
+	if len(cfg.ClickHouseClusters) > 0 || len(cfg.StorageRegions) > 0 {
+		go ch.WatchRoutes(ctx, pg, time.Duration(cfg.ClickHouseRouteSyncSec)*time.Second)
+	}
+
 	// --- Initialize JAR runner ---
 	jarRunner := jar.NewRunner(cfg.JARPath, cfg.JARDefaultHeapMB, cfg.JARTimeoutSec)
+	jarRunner.SetDynamic(settings)
 
 	slog.Info("worker initialized",
 		"jar_path", cfg.JARPath,
-		"heap_mb", cfg.JARDefaultHeapMB,
-		"timeout_sec", cfg.JARTimeoutSec,
+		"heap_mb", settings.JARHeapMB(),
+		"timeout", settings.JARTimeout(),
+		"max_concurrent_jobs", cfg.WorkerMaxConcurrentJobs,
+		"heap_budget_mb", cfg.WorkerHeapBudgetMB,
 	)
 
 	// --- Build ingestion pipeline ---
 	anomalyDetector := worker.NewAnomalyDetector(3.0)
-	pipeline := worker.NewPipeline(pg, ch, s3Client, redis, natsClient, jarRunner, anomalyDetector)
+	progress := streaming.NewProgressPublisher(natsClient, progressMinInterval)
+	progress.SetDynamic(settings)
+	pipeline := worker.NewPipeline(pg, ch, objectStore, redis, progress, jarRunner, anomalyDetector)
+	pipeline.SetDynamic(settings)
+
+	// Legacy JARs analyse log formats older than the layout the default JAR
+	// expects.
+	pipeline.SetLegacyRunners(worker.LegacyRunners(cfg.JARLegacyPaths, func(path string) worker.JARRunner {
+		legacyRunner := jar.NewRunner(path, cfg.JARDefaultHeapMB, cfg.JARTimeoutSec)
+		legacyRunner.SetDynamic(settings)
+		return legacyRunner
+	}))
+	pipeline.SetThresholdEvaluator(worker.NewThresholdEvaluator(pg, ch, natsClient))
+	pipeline.Configure(cfg)
+	// Only queued jobs are redelivered after a crash; inline analyses
+	// run without checkpoints.
+	pipeline.SetCheckpoints(cfg.JobCheckpoints)
+	if len(cfg.StorageRegions) > 0 {
+		pipeline.SetRegions(regions)
+	}
+
+	// Usage accounting writes in the background and is flushed on shutdown,
+	// after the running jobs have drained.
+	usageRecorder := usage.NewRecorder(pg, usage.DefaultBufferSize, usage.DefaultFlushInterval)
+	usageCtx, usageCancel := context.WithCancel(context.Background())
+	usageDone := make(chan struct{})
+	go func() {
+		usageRecorder.Run(usageCtx)
+		close(usageDone)
+	}()
+	pipeline.SetUsageRecorder(usageRecorder)
+
+	// The job event log is flushed on shutdown like usage.
+	jobEvents := jobevents.NewAppender(pg, domain.JobEventSourceWorker, jobevents.DefaultBufferSize, cfg.JobEventsMaxPerJob, jobevents.DefaultFlushInterval)
+	jobEventsCtx, jobEventsCancel := context.WithCancel(context.Background())
+	jobEventsDone := make(chan struct{})
+	go func() {
+		jobEvents.Run(jobEventsCtx)
+		close(jobEventsDone)
+	}()
+	pipeline.SetJobEvents(jobEvents)
 
 	// --- Subscribe to NATS job queue (all tenants) ---
+	// The callback blocks until the priority gate picks the job and the
+	// scheduler admits it, so NATS delivery is throttled while the worker
+	// is at capacity.
+	priorityPolicy, err := worker.ParsePriorityPolicy(cfg.JobPriorityPolicy)
+	if err != nil {
+		slog.Error("invalid job priority policy", "error", err)
+		os.Exit(1)
+	}
+	maxQueueWait := time.Duration(cfg.JobMaxQueueWaitSec) * time.Second
+	pipeline.SetQueueEstimator(worker.NewQueueEstimator(pg, cfg.WorkerMaxConcurrentJobs, priorityPolicy, maxQueueWait))
+
+	scheduler := worker.NewScheduler(cfg.WorkerMaxConcurrentJobs, cfg.WorkerHeapBudgetMB, cfg.JARDefaultHeapMB)
+	scheduler.SetDynamic(settings)
+	gate := worker.NewPriorityGate(scheduler, priorityPolicy, maxQueueWait)
 	err = natsClient.SubscribeAllJobSubmits(ctx, func(job domain.AnalysisJob) {
 		logger := slog.With("job_id", job.ID.String(), "tenant_id", job.TenantID.String())
-		logger.Info("received job submission", "file_id", job.FileID.String())
+		logger.Info("received job submission", "file_id", job.FileID.String(), "priority", job.Priority)
 
-		jobCtx, jobCancel := context.WithTimeout(context.Background(), 30*time.Minute)
-		defer jobCancel()
-
-		if err := pipeline.ProcessJob(jobCtx, job); err != nil {
-			logger.Error("job processing failed", "error", err)
-			return
+		err := gate.Dispatch(ctx, job, func(jobCtx context.Context, job domain.AnalysisJob) {
+			if err := pipeline.ProcessJob(jobCtx, job); err != nil {
+				if errors.Is(err, worker.ErrJobCancelled) {
+					logger.Info("job processing cancelled")
+					return
+				}
+				logger.Error("job processing failed", "error", err)
+				return
+			}
+			logger.Info("job processing completed")
+		})
+		if err != nil {
+			if errors.Is(err, worker.ErrHeapBudgetExceeded) {
+				logger.Error("job rejected", "error", err)
+				_ = pipeline.RejectJob(context.Background(), job, err.Error())
+				return
+			}
+			// Shutting down before admission: requeue so another worker
+			// (or this one after restart) picks the job up.
+			logger.Warn("job not admitted, requeueing", "error", err)
+			if err := natsClient.PublishJobSubmit(context.Background(), job.TenantID.String(), job); err != nil {
+				logger.Error("failed to requeue job", "error", err)
+			}
 		}
-		logger.Info("job processing completed")
 	})
 	if err != nil {
 		slog.Error("failed to subscribe to job queue", "error", err)
 		os.Exit(1)
 	}
 
-	slog.Info("worker ready, listening for jobs on NATS")
+	// --- Subscribe to search export requests (all tenants) ---
+	// Exports are I/O bound and run one at a time, outside the JAR scheduler.
+	exporter := worker.NewExporter(pg, ch, objectStore, natsClient, int64(cfg.ExportMaxRows))
+	exporter.SetCache(redis)
+	err = natsClient.SubscribeAllExportSubmits(ctx, func(export domain.SearchExport) {
+		logger := slog.With("export_id", export.ID.String(), "tenant_id", export.TenantID.String())
+		logger.Info("received export request", "job_id", export.JobID.String(), "format", export.Format)
+
+		exportCtx, exportCancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer exportCancel()
+		if err := exporter.ProcessExport(exportCtx, export); err != nil {
+			logger.Error("export failed", "error", err)
+		}
+	})
+	if err != nil {
+		slog.Error("failed to subscribe to export queue", "error", err)
+		os.Exit(1)
+	}
+
+	// --- Record the worker's liveness for the operators' queue status ---
+	hostname, _ := os.Hostname()
+	heartbeat := worker.NewHeartbeat(pg, hostname, cfg.WorkerMaxConcurrentJobs, func() int {
+		running, _ := scheduler.Stats()
+		return running
+	})
+	go func() {
+		ticker := time.NewTicker(worker.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			if err := heartbeat.Beat(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("worker heartbeat failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// --- Periodic background tasks ---
+	// Every replica registers the same tasks; a lock in Postgres lets one
+	// of them run each task at a time. Failures are logged and recorded by
+	// the runner.
+	taskRunner := tasks.NewRunner(pg, heartbeat.WorkerID())
+
+	// Delete expired export objects.
+	taskRunner.Register(tasks.New("export-cleanup", tasks.Every(exportCleanupInterval), 0, func(ctx context.Context) error {
+		n, err := exporter.CleanupExpired(ctx, time.Now().UTC())
+		if n > 0 {
+			slog.Info("expired exports cleaned up", "count", n)
+		}
+		return err
+	}))
+
+	// Send daily tenant digests.
+	if cfg.SMTPHost != "" {
+		notifier := notify.NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+		digests := worker.NewDigestScheduler(pg, ch, notifier)
+		taskRunner.Register(tasks.New("digests", tasks.Every(digestInterval), 0, func(ctx context.Context) error {
+			n, err := digests.RunDue(ctx, time.Now().UTC())
+			if n > 0 {
+				slog.Info("daily digests sent", "count", n)
+			}
+			return err
+		}))
+	} else {
+		slog.Info("SMTP_HOST not set, daily digests disabled")
+	}
+
+	// Reconcile per-tenant retention in ClickHouse.
+	retention := worker.NewRetentionReconciler(pg, ch, storage.TieringConfig{
+		StoragePolicy: cfg.ClickHouseStoragePolicy,
+		ColdVolume:    cfg.ClickHouseColdVolume,
+	})
+	taskRunner.Register(tasks.New("retention", tasks.Every(retentionInterval), 0, func(ctx context.Context) error {
+		n, err := retention.Run(ctx)
+		if n > 0 {
+			slog.Info("tenant retention reconciled", "restamped", n)
+		}
+		return err
+	}))
+
+	// Reconcile tenant usage nightly.
+	usageReconciler := worker.NewUsageReconciler(pg, ch)
+	taskRunner.Register(tasks.New("usage-reconcile", tasks.MustParseCron(usageReconcileSchedule), 0, func(ctx context.Context) error {
+		n, err := usageReconciler.Run(ctx, time.Now())
+		if n > 0 {
+			slog.Info("usage events backfilled", "count", n)
+		}
+		return err
+	}))
+
+	// Sync the status of linked Jira and ServiceNow records.
+	if cfg.TicketingEncryptionKey != "" {
+		keyring, err := ticketing.NewKeyring(cfg.TicketingEncryptionKey)
+		if err != nil {
+			slog.Error("invalid TICKETING_ENCRYPTION_KEY", "error", err)
+			os.Exit(1)
+		}
+		ticketSyncer := worker.NewTicketSyncer(pg, keyring, nil)
+		taskRunner.Register(tasks.New("ticket-sync", tasks.Every(ticketSyncInterval), 0, func(ctx context.Context) error {
+			n, err := ticketSyncer.Run(ctx, time.Now().UTC())
+			if n > 0 {
+				slog.Info("ticket statuses synced", "count", n)
+			}
+			return err
+		}))
+	} else {
+		slog.Info("TICKETING_ENCRYPTION_KEY not set, ticket status sync disabled")
+	}
+
+	// Purge analyses whose trash grace period has passed.
+	purger := worker.NewJobPurger(pg, ch, redis, objectStore, time.Duration(cfg.TrashGraceDays)*24*time.Hour)
+	taskRunner.Register(tasks.New("trash-purge", tasks.Every(trashPurgeInterval), 0, func(ctx context.Context) error {
+		n, err := purger.Run(ctx, time.Now().UTC())
+		if n > 0 {
+			slog.Info("deleted analyses purged", "count", n)
+		}
+		return err
+	}))
+
+	// Purge and archive sandbox tenants past their expiry.
+	sandboxCleaner := worker.NewSandboxCleaner(pg, purger, objectStore)
+	taskRunner.Register(tasks.New("sandbox-cleanup", tasks.Every(sandboxCleanupInterval), 0, func(ctx context.Context) error {
+		n, err := sandboxCleaner.Run(ctx, time.Now().UTC())
+		if n > 0 {
+			slog.Info("expired sandbox tenants archived", "count", n)
+		}
+		return err
+	}))
+
+	// Refit the tenants' job cost models and record the accuracy of the
+	// estimates of completed jobs.
+	costModelFitter := worker.NewCostModelFitter(pg, ch)
+	taskRunner.Register(tasks.New("cost-model-fit", tasks.Every(costModelFitInterval), 0, func(ctx context.Context) error {
+		n, err := costModelFitter.Run(ctx, time.Now().UTC())
+		if n > 0 {
+			slog.Info("job cost models fitted", "count", n)
+		}
+		return err
+	}))
+
+	// Delete expired idempotency keys.
+	taskRunner.Register(tasks.New("idempotency-cleanup", tasks.Every(idempotencyCleanupInterval), 0, func(ctx context.Context) error {
+		n, err := pg.DeleteExpiredIdempotencyKeys(ctx, time.Now().UTC())
+		if n > 0 {
+			slog.Info("expired idempotency keys deleted", "count", n)
+		}
+		return err
+	}))
+
+	taskRunnerDone := make(chan struct{})
+	go func() {
+		taskRunner.Run(ctx)
+		close(taskRunnerDone)
+	}()
+
+	slog.Info("worker ready, listening for jobs on NATS", "worker_id", heartbeat.WorkerID())
 
 	// --- Wait for shutdown signal ---
 	sigCh := make(chan os.Signal, 1)
@@ -117,22 +484,20 @@ func main() {
 
 	slog.Info("received shutdown signal, draining...", "signal", sig)
 	cancel()
+	scheduler.Wait()
+	<-taskRunnerDone
+	if err := heartbeat.Stop(context.Background()); err != nil {
+		slog.Warn("failed to remove worker heartbeat", "error", err)
+	}
+	usageCancel()
+	<-usageDone
+	jobEventsCancel()
+	<-jobEventsDone
 	slog.Info("RemedyIQ Worker stopped")
 }
 
-func setupLogger(level string) {
-	var logLevel slog.Level
-	switch level {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
-	}
+func setupLogger(level slog.Leveler) {
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
+		Level: level,
 	})))
 }
@@ -192,12 +192,12 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_AllDataPresent(t *testing.T) {
 			SQLCount:    1500,
 			FilterCount: 1000,
 			EscCount:    500,
-			LogStart:    now,
-			LogEnd:      now.Add(2 * time.Hour),
+			LogStart:    domain.NewTimestamp(now),
+			LogEnd:      domain.NewTimestamp(now.Add(2 * time.Hour)),
 		},
 		TimeSeries: []domain.TimeSeriesPoint{
 			{
-				Timestamp:     now,
+				Timestamp:     domain.NewTimestamp(now),
 				APICount:      100,
 				SQLCount:      80,
 				FilterCount:   50,
@@ -206,7 +206,7 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_AllDataPresent(t *testing.T) {
 				ErrorCount:    5,
 			},
 			{
-				Timestamp:     now.Add(time.Minute),
+				Timestamp:     domain.NewTimestamp(now.Add(time.Minute)),
 				APICount:      120,
 				SQLCount:      90,
 				FilterCount:   60,
@@ -220,8 +220,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_AllDataPresent(t *testing.T) {
 	gapsData := &domain.GapsResponse{
 		Gaps: []domain.GapEntry{
 			{
-				StartTime:  now.Add(30 * time.Minute),
-				EndTime:    now.Add(35 * time.Minute),
+				StartTime:  domain.NewTimestamp(now.Add(30 * time.Minute)),
+				EndTime:    domain.NewTimestamp(now.Add(35 * time.Minute)),
 				DurationMS: 300000,
 				BeforeLine: 1000,
 				AfterLine:  1001,
@@ -299,8 +299,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_GapsError(t *testing.T) {
 	dashData := &domain.DashboardData{
 		GeneralStats: domain.GeneralStatistics{
 			TotalLines: 100,
-			LogStart:   now,
-			LogEnd:     now.Add(time.Hour),
+			LogStart:   domain.NewTimestamp(now),
+			LogEnd:     domain.NewTimestamp(now.Add(time.Hour)),
 		},
 	}
 
@@ -324,8 +324,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_ExceptionsError(t *testing.T)
 	dashData := &domain.DashboardData{
 		GeneralStats: domain.GeneralStatistics{
 			TotalLines: 100,
-			LogStart:   now,
-			LogEnd:     now.Add(time.Hour),
+			LogStart:   domain.NewTimestamp(now),
+			LogEnd:     domain.NewTimestamp(now.Add(time.Hour)),
 		},
 	}
 
@@ -366,8 +366,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_EmptyGapsAndExceptions(t *test
 	dashData := &domain.DashboardData{
 		GeneralStats: domain.GeneralStatistics{
 			TotalLines: 100,
-			LogStart:   now,
-			LogEnd:     now.Add(time.Hour),
+			LogStart:   domain.NewTimestamp(now),
+			LogEnd:     domain.NewTimestamp(now.Add(time.Hour)),
 		},
 	}
 
@@ -393,8 +393,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_NoTimeSeries(t *testing.T) {
 		GeneralStats: domain.GeneralStatistics{
 			TotalLines: 200,
 			APICount:   100,
-			LogStart:   now,
-			LogEnd:     now.Add(time.Hour),
+			LogStart:   domain.NewTimestamp(now),
+			LogEnd:     domain.NewTimestamp(now.Add(time.Hour)),
 		},
 		TimeSeries: []domain.TimeSeriesPoint{}, // empty
 	}
@@ -419,8 +419,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_ManyGapsTruncated(t *testing.T
 	dashData := &domain.DashboardData{
 		GeneralStats: domain.GeneralStatistics{
 			TotalLines: 100,
-			LogStart:   now,
-			LogEnd:     now.Add(time.Hour),
+			LogStart:   domain.NewTimestamp(now),
+			LogEnd:     domain.NewTimestamp(now.Add(time.Hour)),
 		},
 	}
 
@@ -428,8 +428,8 @@ func TestAnomalyNarratorSkill_FetchAnomalyContext_ManyGapsTruncated(t *testing.T
 	gaps := make([]domain.GapEntry, 15)
 	for i := range gaps {
 		gaps[i] = domain.GapEntry{
-			StartTime:  now.Add(time.Duration(i) * time.Minute),
-			EndTime:    now.Add(time.Duration(i)*time.Minute + 30*time.Second),
+			StartTime:  domain.NewTimestamp(now.Add(time.Duration(i) * time.Minute)),
+			EndTime:    domain.NewTimestamp(now.Add(time.Duration(i)*time.Minute + 30*time.Second)),
 			DurationMS: 30000,
 			BeforeLine: i * 100,
 			AfterLine:  i*100 + 1,
@@ -458,7 +458,7 @@ func TestAnomalyNarratorSkill_Execute_WithMockClickHouse_FallbackNoAI(t *testing
 
 	now := time.Date(2025, 6, 15, 10, 0, 0, 0, time.UTC)
 	ch.On("GetDashboardData", mock.Anything, "tenant-1", "job-1", 5).Return(&domain.DashboardData{
-		GeneralStats: domain.GeneralStatistics{TotalLines: 100, LogStart: now, LogEnd: now.Add(time.Hour)},
+		GeneralStats: domain.GeneralStatistics{TotalLines: 100, LogStart: domain.NewTimestamp(now), LogEnd: domain.NewTimestamp(now.Add(time.Hour))},
 	}, nil)
 	ch.On("GetGaps", mock.Anything, "tenant-1", "job-1").Return(&domain.GapsResponse{}, nil)
 	ch.On("GetExceptions", mock.Anything, "tenant-1", "job-1").Return(&domain.ExceptionsResponse{TotalCount: 0}, nil)
@@ -206,8 +206,8 @@ func (s *PerformanceSkill) fetchPerformanceContext(ctx context.Context, tenantID
 		}
 	}
 
-	// Health score for overall context
-	health, err := s.ch.ComputeHealthScore(queryCtx, tenantID, jobID)
+	// Health score for overall context, under the default profile.
+	health, err := s.ch.ComputeHealthScore(queryCtx, tenantID, jobID, nil, nil)
 	if err != nil {
 		s.logger.Warn("failed to compute health score for performance analysis",
 			"error", err, "job_id", jobID, "tenant_id", tenantID)
@@ -173,6 +173,9 @@ func (s *RootCauseSkill) fetchRootCauseContext(ctx context.Context, tenantID, jo
 			fmt.Fprintf(&b, "- %s to %s: %dms gap (type: %s)\n",
 				g.StartTime.Format("15:04:05"), g.EndTime.Format("15:04:05"),
 				g.DurationMS, g.LogType)
+			if g.Hint != nil {
+				fmt.Fprintf(&b, "  - likely cause: %s (%s)\n", g.Hint.Classification, g.Hint.Reason)
+			}
 		}
 	}
 
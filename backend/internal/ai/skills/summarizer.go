@@ -176,8 +176,8 @@ func (s *SummarizerSkill) fetchLogContext(ctx context.Context, tenantID, jobID s
 		}
 	}
 
-	// Health score
-	health, err := s.ch.ComputeHealthScore(queryCtx, tenantID, jobID)
+	// Health score, under the default profile.
+	health, err := s.ch.ComputeHealthScore(queryCtx, tenantID, jobID, nil, nil)
 	if err != nil {
 		s.logger.Warn("failed to compute health score for summarizer",
 			"error", err, "job_id", jobID, "tenant_id", tenantID)
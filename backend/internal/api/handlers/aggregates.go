@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
@@ -12,56 +16,123 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
+const (
+	defaultAggregatePageLimit = 50
+	maxAggregatePageLimit     = 500
+)
+
+// aggregatePageParams are the query parameters asking for a page of the
+// aggregate groups.
+var aggregatePageParams = []string{"limit", "offset", "sort_by", "sort_order", "name"}
+
 type AggregatesHandler struct {
-	pg    storage.PostgresStore
-	ch    storage.ClickHouseStore
-	redis storage.RedisCache
+	*sectionHandler[domain.AggregatesResponse]
 }
 
 func NewAggregatesHandler(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *AggregatesHandler {
-	return &AggregatesHandler{pg: pg, ch: ch, redis: redis}
+	return &AggregatesHandler{newSectionHandler(pg, ch, redis, aggregatesSection)}
 }
 
+// ServeHTTP serves the whole cached section, or, for requests with any of
+// aggregatePageParams, one page of the groups of each aggregate, read from
+// ClickHouse with the number of groups matching the name filter. Pages are
+// cached like the sections read from ClickHouse.
 func (h *AggregatesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	tenantID := middleware.GetTenantID(r.Context())
-	if tenantID == "" {
-		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+	if !isAggregatePageRequest(r.URL.Query()) {
+		h.sectionHandler.ServeHTTP(w, r)
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	job, jobID, ok := h.completedJob(w, r)
+	if !ok {
 		return
 	}
-
-	tid, err := uuid.Parse(tenantID)
+	page, err := parseAggregatePage(r.URL.Query())
 	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	format, ok := tableFormat(r)
+	if !ok {
+		badTableFormat(w)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	variant := fmt.Sprintf("aggregates:%d:%d:%s:%s:%s", page.Limit, page.Offset, page.SortBy, page.SortOrder, page.Name)
+	etag := sectionETag(job, sectionVariant(r, variant, format))
+	if sectionNotModified(w, r, etag) {
 		return
 	}
 
-	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	tenantID := middleware.GetTenantID(r.Context())
+	cacheKey := h.redis.TenantKey(tenantID, "dashboard", jobID.String()) + ":" + variant
+	data, cachedAt, err := readLive(r.Context(), h.redis, cacheKey, func(ctx context.Context) (*domain.AggregatesResponse, error) {
+		return h.ch.GetAggregatesPage(ctx, tenantID, jobID.String(), page)
+	})
+	if errors.Is(err, errClickHouseUnavailable) {
+		clickHouseUnavailable(w, h.section.name)
+		return
+	}
 	if err != nil {
-		if storage.IsNotFound(err) {
-			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
-		} else {
-			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
-		}
+		slog.Error("failed to page aggregates", "tenant_id", tenantID, "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, h.section.name+" data not available")
 		return
 	}
 
-	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+	if format != "" {
+		if cachedAt != nil {
+			setDegradedHeaders(w, *cachedAt)
+		}
+		writeSectionTable(w, r, h.pg, job, etag, h.section.name, data, format, cachedAt == nil)
 		return
 	}
+	writeLiveSection(w, etag, data, cachedAt)
+}
 
-	data, err := getOrComputeAggregates(r.Context(), h.redis, tenantID, jobID.String())
-	if err != nil {
-		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "aggregates data not available")
-		return
+// isAggregatePageRequest reports whether q asks for a page of the aggregate
+// groups.
+func isAggregatePageRequest(q url.Values) bool {
+	for _, name := range aggregatePageParams {
+		if q.Get(name) != "" {
+			return true
+		}
 	}
+	return false
+}
 
-	api.JSON(w, http.StatusOK, data)
+// parseAggregatePage reads the page of aggregate groups q asks for. Out of
+// range limits and offsets and unknown sort orders fall back to their
+// defaults, the top 50 groups by total time; an unknown sort_by is an
+// error.
+func parseAggregatePage(q url.Values) (domain.AggregatePage, error) {
+	page := domain.AggregatePage{
+		Limit:     defaultAggregatePageLimit,
+		SortBy:    domain.AggregateSortTotalMS,
+		SortOrder: "desc",
+		Name:      strings.TrimSpace(q.Get("name")),
+	}
+	if v := q.Get("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= maxAggregatePageLimit {
+			page.Limit = parsed
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			page.Offset = parsed
+		}
+	}
+	if v := q.Get("sort_by"); v != "" {
+		page.SortBy = domain.AggregateSort(v)
+		if !page.SortBy.Valid() {
+			sorts := make([]string, len(domain.AggregateSorts))
+			for i, s := range domain.AggregateSorts {
+				sorts[i] = string(s)
+			}
+			return page, fmt.Errorf("sort_by must be one of %s", strings.Join(sorts, ", "))
+		}
+	}
+	if q.Get("sort_order") == "asc" {
+		page.SortOrder = "asc"
+	}
+	return page, nil
 }
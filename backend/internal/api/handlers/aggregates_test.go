@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
@@ -200,3 +201,134 @@ func TestAggregatesHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestAggregatesHandler_Page(t *testing.T) {
+	tenantID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	jobID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	job := &domain.AnalysisJob{ID: jobID, TenantID: tenantID, Status: domain.JobStatusComplete}
+
+	baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID, jobID)
+	// missing is a cache holding no page and no stale copy of one.
+	missing := func() *testutil.MockRedisCache {
+		redis := new(testutil.MockRedisCache)
+		redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+		redis.On("Get", mock.Anything, mock.Anything).Return("", errors.New("redis: nil"))
+		redis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		return redis
+	}
+	serveWith := func(ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache, query string) *httptest.ResponseRecorder {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, tenantID, jobID).Return(job, nil)
+		pg.On("GetLogFile", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("postgres: log file not found")).Maybe()
+		return newTestRequest(http.MethodGet, "/api/v1/analysis/"+jobID.String()+"/dashboard/aggregates?"+query).
+			tenant(tenantID.String()).vars("job_id", jobID.String()).
+			serve(NewAggregatesHandler(pg, ch, redis))
+	}
+	serve := func(ch *testutil.MockClickHouseStore, query string) *httptest.ResponseRecorder {
+		return serveWith(ch, missing(), query)
+	}
+
+	t.Run("page is read from ClickHouse", func(t *testing.T) {
+		want := domain.AggregatePage{Limit: 10, Offset: 20, SortBy: domain.AggregateSortErrorRate, SortOrder: "asc", Name: "HPD"}
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetAggregatesPage", mock.Anything, tenantID.String(), jobID.String(), want).Return(&domain.AggregatesResponse{
+			API: &domain.AggregateSection{
+				Groups:      []domain.AggregateGroup{{Name: "HPD:Help Desk", Count: 4}},
+				GrandTotal:  &domain.AggregateGroup{Name: "Total", Count: 900},
+				TotalGroups: 31,
+			},
+			Page: &want,
+		}, nil)
+
+		w := serve(ch, "limit=10&offset=20&sort_by=error_rate&sort_order=asc&name=+HPD+")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+		var resp domain.AggregatesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 31, resp.API.TotalGroups)
+		assert.Equal(t, &want, resp.Page)
+		ch.AssertExpectations(t)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetAggregatesPage", mock.Anything, tenantID.String(), jobID.String(),
+			domain.AggregatePage{Limit: 50, SortBy: domain.AggregateSortTotalMS, SortOrder: "desc"}).
+			Return(&domain.AggregatesResponse{}, nil)
+
+		w := serve(ch, "limit=100000&offset=-1&sort_order=sideways")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		ch.AssertExpectations(t)
+	})
+
+	t.Run("invalid sort field", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		w := serve(ch, "sort_by=name")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "sort_by must be one of count, total_ms, avg_ms, error_rate")
+		ch.AssertNotCalled(t, "GetAggregatesPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("query failure", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetAggregatesPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("boom"))
+		w := serve(ch, "limit=5")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("page is cached with a stale copy", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetAggregatesPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&domain.AggregatesResponse{}, nil)
+		redis := missing()
+		w := serveWith(ch, redis, "limit=5")
+		require.Equal(t, http.StatusOK, w.Code)
+		key := baseKey + ":aggregates:5:0:total_ms:desc:"
+		redis.AssertCalled(t, "Set", mock.Anything, key, mock.Anything, sectionCacheTTL)
+		redis.AssertCalled(t, "Set", mock.Anything, key+":stale", mock.Anything, staleSectionTTL)
+	})
+
+	t.Run("clickhouse down serves the stale page", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetAggregatesPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errClickHouseDown)
+		key := baseKey + ":aggregates:5:0:total_ms:desc:"
+		redis := new(testutil.MockRedisCache)
+		redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+		redis.On("Get", mock.Anything, key).Return("", errors.New("redis: nil"))
+		redis.On("Get", mock.Anything, key+":stale").
+			Return(`{"cached_at":"2026-02-03T11:00:00Z","data":{"api":{"groups":[{"name":"HPD:Help Desk","count":4}],"total_groups":1}}}`, nil)
+
+		w := serveWith(ch, redis, "limit=5")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Empty(t, w.Header().Get("ETag"))
+		var resp struct {
+			domain.AggregatesResponse
+			Degraded bool   `json:"degraded"`
+			CachedAt string `json:"cached_at"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Degraded)
+		assert.Equal(t, "2026-02-03T11:00:00Z", resp.CachedAt)
+		require.NotNil(t, resp.API)
+		assert.Equal(t, 1, resp.API.TotalGroups)
+
+		w = serveWith(ch, redis, "limit=5&format=csv")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "clickhouse_unavailable", w.Header().Get("X-Degraded"))
+		assert.Equal(t, "2026-02-03T11:00:00Z", w.Header().Get("X-Cached-At"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Contains(t, w.Body.String(), "HPD:Help Desk")
+	})
+
+	t.Run("clickhouse down without a cached page", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetAggregatesPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errClickHouseDown)
+		w := serve(ch, "limit=5")
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+		var resp api.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, api.ErrCodeServiceUnavail, resp.Code)
+		assert.Equal(t, map[string]any{"reason": "clickhouse_unavailable", "section": "aggregates"}, resp.Details)
+	})
+}
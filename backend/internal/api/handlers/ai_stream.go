@@ -18,6 +18,7 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/usage"
 )
 
 type AIStreamHandler struct {
@@ -27,10 +28,11 @@ type AIStreamHandler struct {
 	db       *storage.PostgresClient
 	ch       storage.ClickHouseStore
 	redis    storage.RedisCache
+	usage    *usage.Recorder
 	logger   *slog.Logger
 }
 
-func NewAIStreamHandler(gemini *ai.GeminiClient, registry *ai.Registry, router *ai.Router, db *storage.PostgresClient, ch storage.ClickHouseStore, redis storage.RedisCache) *AIStreamHandler {
+func NewAIStreamHandler(gemini *ai.GeminiClient, registry *ai.Registry, router *ai.Router, db *storage.PostgresClient, ch storage.ClickHouseStore, redis storage.RedisCache, usage *usage.Recorder) *AIStreamHandler {
 	return &AIStreamHandler{
 		gemini:   gemini,
 		registry: registry,
@@ -38,6 +40,7 @@ func NewAIStreamHandler(gemini *ai.GeminiClient, registry *ai.Registry, router *
 		db:       db,
 		ch:       ch,
 		redis:    redis,
+		usage:    usage,
 		logger:   slog.Default().With("handler", "ai_stream"),
 	}
 }
@@ -48,9 +51,8 @@ func (h *AIStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
 		return
 	}
-	tenantUUID, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant ID")
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -85,9 +87,13 @@ func (h *AIStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	req.JobID = jobID
-	jobUUID, err := uuid.Parse(req.JobID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id")
+	jobUUID, ok := bodyID(w, "job_id", req.JobID)
+	if !ok {
+		return
+	}
+	// The body job_id is not seen by the router's JobGuard.
+	if _, err := h.db.GetJob(r.Context(), tenantUUID, jobUUID); err != nil {
+		writeJobError(w, jobUUID, "failed to retrieve analysis job", err)
 		return
 	}
 
@@ -213,6 +219,12 @@ func (h *AIStreamHandler) streamSSE(ctx context.Context, w http.ResponseWriter,
 	if err := h.db.UpdateMessageContent(ctx, tenantUUID, assistantMsg.ID, assistantMsg.Content, assistantMsg.TokensUsed, assistantMsg.LatencyMS, assistantMsg.Status, assistantMsg.FollowUps); err != nil {
 		h.logger.Error("failed to update message", "error", err)
 	}
+	h.usage.Record(domain.UsageEvent{
+		TenantID: tenantUUID,
+		Metric:   domain.UsageAIQueries,
+		SourceID: assistantMsg.ID,
+		Amount:   1,
+	})
 
 	h.writeSSE(w, flusher, "metadata", ai.SSEMetadataData{
 		TokensUsed: totalTokens,
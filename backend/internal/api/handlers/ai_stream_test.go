@@ -41,7 +41,7 @@ func (m *mockGeminiClient) IsAvailable() bool {
 func TestAIStreamHandler_MissingTenantContext(t *testing.T) {
 	registry := ai.NewRegistry()
 	router := ai.NewRouter()
-	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil)
+	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil, nil)
 
 	body := `{"query":"test"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/ai/stream", bytes.NewBufferString(body))
@@ -55,7 +55,7 @@ func TestAIStreamHandler_MissingTenantContext(t *testing.T) {
 func TestAIStreamHandler_MissingUserContext(t *testing.T) {
 	registry := ai.NewRegistry()
 	router := ai.NewRouter()
-	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil)
+	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil, nil)
 
 	body := `{"query":"test","job_id":"` + uuid.New().String() + `"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/ai/stream", bytes.NewBufferString(body))
@@ -71,7 +71,7 @@ func TestAIStreamHandler_MissingUserContext(t *testing.T) {
 func TestAIStreamHandler_MissingJobID(t *testing.T) {
 	registry := ai.NewRegistry()
 	router := ai.NewRouter()
-	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil)
+	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil, nil)
 
 	body := `{"query":"test"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/ai/stream", bytes.NewBufferString(body))
@@ -89,7 +89,7 @@ func TestAIStreamHandler_MissingJobID(t *testing.T) {
 func TestAIStreamHandler_MissingQuery(t *testing.T) {
 	registry := ai.NewRegistry()
 	router := ai.NewRouter()
-	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil)
+	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil, nil)
 
 	body := `{}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/ai/stream", bytes.NewBufferString(body))
@@ -107,7 +107,7 @@ func TestAIStreamHandler_MissingQuery(t *testing.T) {
 func TestAIStreamHandler_QueryTooLong(t *testing.T) {
 	registry := ai.NewRegistry()
 	router := ai.NewRouter()
-	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil)
+	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil, nil)
 
 	longQuery := strings.Repeat("a", 2001)
 	body := `{"query":"` + longQuery + `"}`
@@ -158,7 +158,7 @@ func TestAIStreamHandler_SSETokenEventFormat(t *testing.T) {
 func TestAIStreamHandler_BuildSystemPrompt_UsesSummaryContext(t *testing.T) {
 	registry := ai.NewRegistry()
 	router := ai.NewRouter()
-	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil)
+	h := NewAIStreamHandler(nil, registry, router, nil, nil, nil, nil)
 
 	prompt := h.buildSystemPrompt("nl_query", "## Log Analysis Summary")
 
@@ -1,38 +1,119 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/mux"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
 )
 
 // analysisJobCreateRequest matches ~= OpenAPI AnalysisJobCreate schema.
 type analysisJobCreateRequest struct {
-	FileID   string           `json:"file_id"`
-	JARFlags *domain.JARFlags `json:"jar_flags,omitempty"`
+	FileID           string           `json:"file_id"`
+	JARFlags         *domain.JARFlags `json:"jar_flags,omitempty"`
+	CorrectClockSkew bool             `json:"correct_clock_skew,omitempty"`
+
+	// Priority overrides the priority chosen from the file size. Only
+	// administrators may raise it.
+	Priority domain.JobPriority `json:"priority,omitempty"`
+
+	// Sampling opts into ingesting a sample of the capture.
+	Sampling *analysisSamplingRequest `json:"sampling,omitempty"`
+
+	// Sync asks for a small file to be analysed within the request. Unset,
+	// small files are analysed inline when the server does so by default;
+	// false always queues the job.
+	Sync *bool `json:"sync,omitempty"`
+}
+
+// analysisSamplingRequest is the sampled ingestion asked for with a job. A
+// zero slow threshold selects the default.
+type analysisSamplingRequest struct {
+	Rate            int    `json:"rate"`
+	SlowThresholdMS uint32 `json:"slow_threshold_ms,omitempty"`
+}
+
+// InlineAnalyzer analyses small jobs within the request creating them.
+// worker.InlineRunner implements it.
+type InlineAnalyzer interface {
+	Eligible(sizeBytes int64) bool
+	Run(ctx context.Context, job domain.AnalysisJob) error
 }
 
 // AnalysisHandlers provides HTTP handlers for analysis job endpoints.
 type AnalysisHandlers struct {
-	pg   storage.PostgresStore
-	nats streaming.NATSStreamer
+	pg     storage.PostgresStore
+	nats   streaming.NATSStreamer
+	queue  *worker.QueueEstimator
+	events *jobevents.Appender
+	admins *middleware.AdminMiddleware
+
+	// inline, when set, analyses small files within the request; by
+	// default only when inlineAuto is on or the request asks for it.
+	inline     InlineAnalyzer
+	inlineAuto bool
+
+	// estimator, when set, estimates the cost of analyses before they are
+	// submitted and stores the estimate with each new job.
+	estimator *worker.JobEstimator
+
+	// redis, when set, holds the flags of the cancellations requested.
+	redis storage.RedisCache
+
+	stamps
+}
+
+// NewAnalysisHandlers creates the analysis handlers. queue, which may be
+// nil, adds the queue position to queued jobs. events, which may be nil,
+// records job creation and submission to the job event log. adminUserIDs
+// may submit jobs at a higher priority than their file size gives.
+func NewAnalysisHandlers(pg storage.PostgresStore, nats streaming.NATSStreamer, queue *worker.QueueEstimator, events *jobevents.Appender, adminUserIDs []string) *AnalysisHandlers {
+	return &AnalysisHandlers{pg: pg, nats: nats, queue: queue, events: events, admins: middleware.NewAdminMiddleware(adminUserIDs)}
+}
+
+// SetInlineAnalyzer makes small files be analysed within the request that
+// creates their job: always when auto is on and the request does not ask
+// for the queue, otherwise when it asks for sync.
+func (h *AnalysisHandlers) SetInlineAnalyzer(a InlineAnalyzer, auto bool) {
+	h.inline = a
+	h.inlineAuto = auto
 }
 
-func NewAnalysisHandlers(pg storage.PostgresStore, nats streaming.NATSStreamer) *AnalysisHandlers {
-	return &AnalysisHandlers{pg: pg, nats: nats}
+// SetJobEstimator enables cost estimates, both on request and stored with
+// each job created.
+func (h *AnalysisHandlers) SetJobEstimator(e *worker.JobEstimator) {
+	h.estimator = e
 }
 
-// CreateAnalysis handles POST /api/v1/analysis.
+// runInline reports whether a job of a file of sizeBytes is analysed
+// inline, given the sync option of its request.
+func (h *AnalysisHandlers) runInline(sync *bool, sizeBytes int64) bool {
+	if h.inline == nil || !h.inline.Eligible(sizeBytes) {
+		return false
+	}
+	if sync != nil {
+		return *sync
+	}
+	return h.inlineAuto
+}
+
+// CreateAnalysis handles POST /api/v1/analysis. Small files may be analysed
+// within the request, which then answers with the completed or failed job.
+// An inline analysis that is out of slots, overruns its deadline or finds
+// the file larger than expected is queued instead, answered with 202.
 func (h *AnalysisHandlers) CreateAnalysis() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tenantID := middleware.GetTenantID(r.Context())
@@ -47,20 +128,65 @@ func (h *AnalysisHandlers) CreateAnalysis() http.Handler {
 			return
 		}
 
-		fileID, err := uuid.Parse(req.FileID)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid file_id")
+		fileID, ok := bodyID(w, "file_id", req.FileID)
+		if !ok {
 			return
 		}
 
-		tid, err := uuid.Parse(tenantID)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+		tid, ok := requestTenant(w, r)
+		if !ok {
 			return
 		}
 
+		if req.JARFlags != nil {
+			switch req.JARFlags.OutputFormat {
+			case "", domain.JAROutputText, domain.JAROutputXML, domain.JAROutputJSON:
+			default:
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "jar_flags.output_format must be text, xml or json")
+				return
+			}
+		}
+		if req.Priority != "" && !req.Priority.Valid() {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "priority must be interactive, normal or batch")
+			return
+		}
+		var sampling *domain.Sampling
+		if req.Sampling != nil {
+			if req.Sampling.Rate < domain.MinSampleRate || req.Sampling.Rate > domain.MaxSampleRate {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+					fmt.Sprintf("sampling.rate must be between %d and %d", domain.MinSampleRate, domain.MaxSampleRate))
+				return
+			}
+			sampling = &domain.Sampling{Rate: req.Sampling.Rate, SlowThresholdMS: req.Sampling.SlowThresholdMS}
+			if sampling.SlowThresholdMS == 0 {
+				sampling.SlowThresholdMS = domain.DefaultSamplingSlowThresholdMS
+			}
+		}
+
+		// Sandbox tenants may hold a few analyses, and start none once
+		// expired.
+		tenant := lookupTenant(r.Context(), h.pg, tid)
+		if tenant.SandboxExpired(h.now()) {
+			api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "sandbox tenant has expired")
+			return
+		}
+		if limit := tenant.Quotas(domain.TenantQuotas{}).MaxAnalyses; limit > 0 {
+			n, err := h.pg.CountJobs(r.Context(), tid)
+			if err != nil {
+				slog.Error("failed to count analyses", "tenant_id", tid, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to count analyses")
+				return
+			}
+			if n >= limit {
+				api.Error(w, http.StatusForbidden, api.ErrCodeQuotaExceeded,
+					fmt.Sprintf("the tenant holds its limit of %d analyses; delete some to analyse more", limit))
+				return
+			}
+		}
+
 		// Verify the file exists and belongs to this tenant.
-		if _, err := h.pg.GetLogFile(r.Context(), tid, fileID); err != nil {
+		file, err := h.pg.GetLogFile(r.Context(), tid, fileID)
+		if err != nil {
 			if storage.IsNotFound(err) {
 				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "file not found")
 			} else {
@@ -70,6 +196,18 @@ func (h *AnalysisHandlers) CreateAnalysis() http.Handler {
 			return
 		}
 
+		// Small files jump the queue. Anyone may lower the priority of their
+		// job; raising it is reserved for administrators.
+		priority := domain.DefaultJobPriority(file.SizeBytes)
+		if req.Priority != "" {
+			if req.Priority.Rank() < priority.Rank() && !h.admins.IsAdmin(middleware.GetUserID(r.Context())) {
+				api.Error(w, http.StatusForbidden, api.ErrCodeForbidden,
+					"priority above "+string(priority)+" for this file requires administrator access")
+				return
+			}
+			priority = req.Priority
+		}
+
 		flags := domain.JARFlags{}
 		if req.JARFlags != nil {
 			flags = *req.JARFlags
@@ -79,24 +217,64 @@ func (h *AnalysisHandlers) CreateAnalysis() http.Handler {
 		}
 
 		job := &domain.AnalysisJob{
-			ID:        uuid.New(),
+			ID:        h.newID(),
 			TenantID:  tid,
 			FileID:    fileID,
 			Status:    domain.JobStatusQueued,
+			Priority:  priority,
 			JARFlags:  flags,
-			CreatedAt: time.Now().UTC(),
-			UpdatedAt: time.Now().UTC(),
+			CreatedAt: h.now(),
+			UpdatedAt: h.now(),
+
+			CorrectClockSkew: req.CorrectClockSkew,
+			Sampling:         sampling,
+			Sandbox:          tenant != nil && tenant.Sandbox,
+		}
+		if h.estimator != nil {
+			in := worker.EstimateInput{SizeBytes: file.SizeBytes, SourceType: fileSourceType(file)}
+			if sampling != nil {
+				in.SampleRate = sampling.Rate
+			}
+			est, err := h.estimator.Estimate(r.Context(), tid, priority, in)
+			if err != nil {
+				slog.Warn("failed to estimate analysis cost", "tenant_id", tid, "file_id", fileID, "error", err)
+			}
+			job.Estimate = est
 		}
 
 		if err := h.pg.CreateJob(r.Context(), job); err != nil {
 			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create analysis job")
 			return
 		}
+		h.events.Append(domain.JobEvent{TenantID: tid, JobID: job.ID, Kind: domain.JobEventCreated,
+			Metadata: map[string]any{"priority": string(priority), "file_id": fileID.String(), "size_bytes": file.SizeBytes}})
+
+		status := http.StatusCreated
+		if h.runInline(req.Sync, file.SizeBytes) {
+			err := h.inline.Run(r.Context(), *job)
+			if !errors.Is(err, worker.ErrInlineFallback) {
+				if err != nil {
+					slog.Warn("inline analysis failed", "job_id", job.ID, "error", err)
+				}
+				done, getErr := h.pg.GetJob(storage.WithPrimary(r.Context()), tid, job.ID)
+				if getErr != nil {
+					slog.Error("failed to read back inline analysis", "job_id", job.ID, "error", getErr)
+					api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+					return
+				}
+				api.JSON(w, http.StatusCreated, done)
+				return
+			}
+			status = http.StatusAccepted
+		}
 
 		// Publish to NATS for worker pickup.
 		if err := h.nats.PublishJobSubmit(r.Context(), tenantID, *job); err != nil {
 			// Job is created but failed to queue -- update status.
 			errMsg := "failed to queue job: " + err.Error()
+			h.events.Append(domain.JobEvent{TenantID: tid, JobID: job.ID, Kind: domain.JobEventPublish,
+				Message: "job_submit publish failed", Metadata: map[string]any{"subject": "job_submit", "error": err.Error()}})
+			h.events.Append(domain.JobEvent{TenantID: tid, JobID: job.ID, Kind: domain.JobEventFailed, Message: errMsg})
 			if updateErr := h.pg.UpdateJobStatus(r.Context(), tid, job.ID, domain.JobStatusFailed, &errMsg); updateErr != nil {
 				slog.Error("failed to update job status after NATS publish failure",
 					"job_id", job.ID, "tenant_id", tenantID, "error", updateErr)
@@ -104,12 +282,22 @@ func (h *AnalysisHandlers) CreateAnalysis() http.Handler {
 			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to queue analysis job")
 			return
 		}
+		h.events.Append(domain.JobEvent{TenantID: tid, JobID: job.ID, Kind: domain.JobEventPublish,
+			Message: "job_submit published", Metadata: map[string]any{"subject": "job_submit"}})
+
+		// The new job may have moved others back in the queue.
+		if h.queue != nil {
+			if err := h.queue.PublishQueued(r.Context(), h.nats); err != nil {
+				slog.Warn("failed to update queue estimates", "job_id", job.ID, "error", err)
+			}
+		}
 
-		api.JSON(w, http.StatusCreated, job)
+		api.JSON(w, status, job)
 	})
 }
 
-// ListAnalyses handles GET /api/v1/analysis.
+// ListAnalyses handles GET /api/v1/analysis. The optional
+// investigation_status and assignee query parameters narrow the list.
 func (h *AnalysisHandlers) ListAnalyses() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tenantID := middleware.GetTenantID(r.Context())
@@ -118,17 +306,32 @@ func (h *AnalysisHandlers) ListAnalyses() http.Handler {
 			return
 		}
 
-		tid, err := uuid.Parse(tenantID)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		filter := domain.JobListFilter{
+			InvestigationStatus: domain.InvestigationStatus(r.URL.Query().Get("investigation_status")),
+			Assignee:            r.URL.Query().Get("assignee"),
+		}
+		if filter.InvestigationStatus != "" && !validInvestigationStatus(filter.InvestigationStatus) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid investigation_status")
 			return
 		}
 
-		jobs, err := h.pg.ListJobs(r.Context(), tid)
+		var jobs []domain.AnalysisJob
+		var err error
+		if filter == (domain.JobListFilter{}) {
+			jobs, err = h.pg.ListJobs(r.Context(), tid)
+		} else {
+			jobs, err = h.pg.ListJobsFiltered(r.Context(), tid, filter)
+		}
 		if err != nil {
 			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list analysis jobs")
 			return
 		}
+		h.attachLinks(r.Context(), tid, jobs)
 
 		api.JSON(w, http.StatusOK, map[string]interface{}{
 			"jobs": jobs,
@@ -142,6 +345,34 @@ func (h *AnalysisHandlers) ListAnalyses() http.Handler {
 	})
 }
 
+// attachLinks sets the links of each job, with the status last synced. The
+// list is served without links when they cannot be read.
+func (h *AnalysisHandlers) attachLinks(ctx context.Context, tid uuid.UUID, jobs []domain.AnalysisJob) {
+	if len(jobs) == 0 {
+		return
+	}
+	ids := make([]uuid.UUID, len(jobs))
+	for i := range jobs {
+		ids[i] = jobs[i].ID
+	}
+	byJob, err := h.pg.ListAnalysisLinksByJobs(ctx, tid, ids)
+	if err != nil {
+		slog.Warn("failed to list analysis links", "tenant_id", tid, "error", err)
+		return
+	}
+	if len(byJob) == 0 {
+		return
+	}
+	groups := make([][]domain.AnalysisLink, 0, len(byJob))
+	for _, links := range byJob {
+		groups = append(groups, links)
+	}
+	fillLinkURLs(ctx, h.pg, tid, groups...)
+	for i := range jobs {
+		jobs[i].Links = byJob[jobs[i].ID]
+	}
+}
+
 // GetAnalysis handles GET /api/v1/analysis/{job_id}.
 func (h *AnalysisHandlers) GetAnalysis() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -151,16 +382,13 @@ func (h *AnalysisHandlers) GetAnalysis() http.Handler {
 			return
 		}
 
-		jobIDStr := mux.Vars(r)["job_id"]
-		jobID, err := uuid.Parse(jobIDStr)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
 			return
 		}
 
-		tid, err := uuid.Parse(tenantID)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+		tid, ok := requestTenant(w, r)
+		if !ok {
 			return
 		}
 
@@ -175,6 +403,67 @@ func (h *AnalysisHandlers) GetAnalysis() http.Handler {
 			return
 		}
 
+		if job.Status == domain.JobStatusQueued && h.queue != nil {
+			queue, err := h.queue.Estimate(r.Context(), job.ID)
+			if err != nil {
+				slog.Warn("failed to estimate queue position", "job_id", jobID, "error", err)
+			}
+			job.Queue = queue
+		}
+
 		api.JSON(w, http.StatusOK, job)
 	})
 }
+
+// JobEvents handles GET /api/v1/analysis/{job_id}/events, replaying the
+// job's event log as a timeline with the time between consecutive events.
+// For jobs still running, idle_ms is the time since the last event, which
+// tells a stuck job from a slow one.
+func (h *AnalysisHandlers) JobEvents() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		job, err := h.pg.GetJob(r.Context(), tid, jobID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				slog.Error("failed to retrieve job", "job_id", jobID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+
+		events, err := h.pg.ListJobEvents(r.Context(), tid, jobID)
+		if err != nil {
+			slog.Error("failed to list job events", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list job events")
+			return
+		}
+		timeline := jobevents.Timeline(events)
+
+		resp := map[string]interface{}{
+			"job_id": jobID,
+			"status": job.Status,
+			"events": timeline,
+		}
+		if n := len(timeline); n > 0 && !job.Status.Terminal() {
+			resp["idle_ms"] = max(time.Since(timeline[n-1].OccurredAt).Milliseconds(), 0)
+		}
+		api.JSON(w, http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// SetCancelFlags makes cancellation requests also flag the job in Redis,
+// which the worker running it polls more often than it reads the job.
+func (h *AnalysisHandlers) SetCancelFlags(redis storage.RedisCache) {
+	h.redis = redis
+}
+
+// CancelAnalysis handles POST /api/v1/analyses/{job_id}/cancel. A queued
+// job is cancelled at once, answered with 200; the worker skips it on
+// receipt. A running job is flagged for its worker, which stops it, deletes
+// what it stored and marks it cancelled, answered with 202. A job already
+// cancelled is answered as it is, and one that completed or failed cannot
+// be cancelled.
+func (h *AnalysisHandlers) CancelAnalysis() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		job, requested, err := h.pg.RequestJobCancel(r.Context(), tid, jobID)
+		if err != nil {
+			writeJobError(w, jobID, "failed to cancel analysis job", err)
+			return
+		}
+		logger := slog.With("tenant_id", tid, "job_id", jobID, "user_id", middleware.GetUserID(r.Context()))
+
+		switch {
+		case !requested && job.Status == domain.JobStatusCancelled:
+			api.JSON(w, http.StatusOK, job)
+		case !requested:
+			api.Error(w, http.StatusConflict, api.ErrCodeConflict, "analysis is already "+string(job.Status))
+		case job.Status == domain.JobStatusCancelled:
+			logger.Info("queued analysis cancelled")
+			h.publishCancelled(r.Context(), *job)
+			api.JSON(w, http.StatusOK, job)
+		default:
+			// Without the flag the worker still finds the request on the
+			// job, only later.
+			if h.redis != nil {
+				key := worker.JobCancelKey(h.redis, tid.String(), jobID.String())
+				if err := h.redis.Set(r.Context(), key, "1", worker.CancelFlagTTL); err != nil {
+					logger.Warn("failed to flag cancellation", "error", err)
+				}
+			}
+			logger.Info("analysis cancellation requested", "status", job.Status)
+			api.JSON(w, http.StatusAccepted, job)
+		}
+	})
+}
+
+// publishCancelled publishes the terminal progress and completion of a job
+// cancelled while queued, as the worker does for the jobs it cancels, and
+// updates the queue positions of the jobs behind it.
+func (h *AnalysisHandlers) publishCancelled(ctx context.Context, job domain.AnalysisJob) {
+	tenantID := job.TenantID.String()
+	msg := worker.ErrJobCancelled.Error()
+	if err := h.nats.PublishJobProgress(ctx, tenantID, job.ID.String(), 0, string(domain.JobStatusCancelled), msg); err != nil {
+		slog.Warn("failed to publish cancellation progress", "job_id", job.ID, "error", err)
+	}
+	if err := h.nats.PublishJobComplete(ctx, tenantID, job.ID.String(), job); err != nil {
+		slog.Warn("failed to publish cancellation", "job_id", job.ID, "error", err)
+	}
+	h.events.Append(domain.JobEvent{TenantID: job.TenantID, JobID: job.ID, Kind: domain.JobEventCancelled, Message: msg})
+	if h.queue != nil {
+		if err := h.queue.PublishQueued(ctx, h.nats); err != nil {
+			slog.Warn("failed to update queue estimates", "job_id", job.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// cancelRedis records the cancellation flags set.
+type cancelRedis struct {
+	testutil.MockRedisCache
+	flags []string
+}
+
+func (r *cancelRedis) Set(_ context.Context, key string, _ interface{}, _ time.Duration) error {
+	r.flags = append(r.flags, key)
+	return nil
+}
+
+func (r *cancelRedis) TenantKey(tenantID, category, id string) string {
+	return tenantID + ":" + category + ":" + id
+}
+
+func TestCancelAnalysis(t *testing.T) {
+	job := func(status domain.JobStatus) *domain.AnalysisJob {
+		now := time.Now()
+		return &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: status, CancelRequestedAt: &now}
+	}
+	flag := fixedTenantID.String() + ":cancel:" + fixedJobID.String()
+
+	tests := []struct {
+		name       string
+		setupMocks func(pg *testutil.MockPostgresStore, nats *testutil.MockNATSStreamer)
+		wantStatus int
+		wantJob    domain.JobStatus
+		wantFlag   bool
+	}{
+		{
+			name: "running job is flagged for its worker",
+			setupMocks: func(pg *testutil.MockPostgresStore, _ *testutil.MockNATSStreamer) {
+				pg.On("RequestJobCancel", mock.Anything, fixedTenantID, fixedJobID).Return(job(domain.JobStatusParsing), true, nil)
+			},
+			wantStatus: http.StatusAccepted,
+			wantJob:    domain.JobStatusParsing,
+			wantFlag:   true,
+		},
+		{
+			name: "queued job is cancelled at once",
+			setupMocks: func(pg *testutil.MockPostgresStore, nats *testutil.MockNATSStreamer) {
+				pg.On("RequestJobCancel", mock.Anything, fixedTenantID, fixedJobID).Return(job(domain.JobStatusCancelled), true, nil)
+				nats.On("PublishJobProgress", mock.Anything, fixedTenantID.String(), fixedJobID.String(), 0, "cancelled", "analysis cancelled").Return(nil)
+				nats.On("PublishJobComplete", mock.Anything, fixedTenantID.String(), fixedJobID.String(),
+					mock.MatchedBy(func(j domain.AnalysisJob) bool { return j.Status == domain.JobStatusCancelled })).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantJob:    domain.JobStatusCancelled,
+		},
+		{
+			name: "cancelled job is answered as it is",
+			setupMocks: func(pg *testutil.MockPostgresStore, _ *testutil.MockNATSStreamer) {
+				pg.On("RequestJobCancel", mock.Anything, fixedTenantID, fixedJobID).Return(job(domain.JobStatusCancelled), false, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantJob:    domain.JobStatusCancelled,
+		},
+		{
+			name: "completed job cannot be cancelled",
+			setupMocks: func(pg *testutil.MockPostgresStore, _ *testutil.MockNATSStreamer) {
+				pg.On("RequestJobCancel", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}, false, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "unknown job",
+			setupMocks: func(pg *testutil.MockPostgresStore, _ *testutil.MockNATSStreamer) {
+				pg.On("RequestJobCancel", mock.Anything, fixedTenantID, fixedJobID).
+					Return(nil, false, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "store fails",
+			setupMocks: func(pg *testutil.MockPostgresStore, _ *testutil.MockNATSStreamer) {
+				pg.On("RequestJobCancel", mock.Anything, fixedTenantID, fixedJobID).Return(nil, false, errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			nats := new(testutil.MockNATSStreamer)
+			redis := &cancelRedis{}
+			tc.setupMocks(pg, nats)
+			h := NewAnalysisHandlers(pg, nats, nil, nil, nil)
+			h.SetCancelFlags(redis)
+
+			w := newTestRequest(http.MethodPost, "/api/v1/analyses/"+fixedJobID.String()+"/cancel").
+				tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).serve(h.CancelAnalysis())
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			pg.AssertExpectations(t)
+			nats.AssertExpectations(t)
+			if tc.wantFlag {
+				assert.Equal(t, []string{flag}, redis.flags)
+			} else {
+				assert.Empty(t, redis.flags)
+			}
+			if tc.wantStatus == http.StatusConflict {
+				assert.Equal(t, api.ErrCodeConflict, decodeError(t, w).Code)
+			}
+			if tc.wantJob == "" {
+				return
+			}
+			var got domain.AnalysisJob
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+			assert.Equal(t, tc.wantJob, got.Status)
+		})
+	}
+}
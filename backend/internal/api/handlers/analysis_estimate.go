@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// analysisEstimateRequest names an uploaded file, or the size and source
+// type of one not uploaded yet.
+type analysisEstimateRequest struct {
+	FileID     string                   `json:"file_id,omitempty"`
+	SizeBytes  int64                    `json:"size_bytes,omitempty"`
+	SourceType domain.LogSourceType     `json:"source_type,omitempty"`
+	Priority   domain.JobPriority       `json:"priority,omitempty"`
+	Sampling   *analysisSamplingRequest `json:"sampling,omitempty"`
+}
+
+// fileSourceType returns the source type of a file, ar_server when unset.
+func fileSourceType(f *domain.LogFile) domain.LogSourceType {
+	if f.SourceType == "" {
+		return domain.LogSourceARServer
+	}
+	return f.SourceType
+}
+
+// EstimateAnalysis handles POST /api/v1/analyses/estimate, estimating what
+// analysing a file would cost before it is submitted: its processing time
+// and queue wait, the rows and storage it adds, whether sampling it is
+// advised and the tenant quotas it would exceed.
+func (h *AnalysisHandlers) EstimateAnalysis() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		if h.estimator == nil {
+			api.Error(w, http.StatusServiceUnavailable, api.ErrCodeServiceUnavail, "cost estimates are not available")
+			return
+		}
+
+		var req analysisEstimateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+
+		in := worker.EstimateInput{SizeBytes: req.SizeBytes, SourceType: req.SourceType}
+		switch {
+		case req.FileID != "":
+			fileID, ok := bodyID(w, "file_id", req.FileID)
+			if !ok {
+				return
+			}
+			file, err := h.pg.GetLogFile(r.Context(), tid, fileID)
+			if err != nil {
+				if storage.IsNotFound(err) {
+					api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "file not found")
+				} else {
+					slog.Error("failed to retrieve file for estimate", "file_id", fileID, "error", err)
+					api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve file")
+				}
+				return
+			}
+			in.SizeBytes = file.SizeBytes
+			in.SourceType = fileSourceType(file)
+		case req.SizeBytes <= 0:
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "file_id or a positive size_bytes is required")
+			return
+		case req.SourceType == "":
+			in.SourceType = domain.LogSourceARServer
+		case !req.SourceType.Valid():
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "unknown source_type")
+			return
+		}
+		if req.Sampling != nil {
+			if req.Sampling.Rate < domain.MinSampleRate || req.Sampling.Rate > domain.MaxSampleRate {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+					fmt.Sprintf("sampling.rate must be between %d and %d", domain.MinSampleRate, domain.MaxSampleRate))
+				return
+			}
+			in.SampleRate = req.Sampling.Rate
+		}
+		priority := domain.DefaultJobPriority(in.SizeBytes)
+		if req.Priority != "" {
+			if !req.Priority.Valid() {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "priority must be interactive, normal or batch")
+				return
+			}
+			priority = req.Priority
+		}
+
+		in.Quotas = lookupTenant(r.Context(), h.pg, tid).Quotas(domain.TenantQuotas{MaxFileBytes: maxUploadSize})
+		if in.Quotas.MaxAnalyses > 0 {
+			var err error
+			if in.Analyses, err = h.pg.CountJobs(r.Context(), tid); err != nil {
+				slog.Error("failed to count analyses", "tenant_id", tid, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to count analyses")
+				return
+			}
+		}
+
+		est, err := h.estimator.Estimate(r.Context(), tid, priority, in)
+		if err != nil {
+			slog.Error("failed to estimate analysis cost", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to estimate analysis cost")
+			return
+		}
+		api.JSON(w, http.StatusOK, est)
+	})
+}
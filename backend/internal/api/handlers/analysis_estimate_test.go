@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// noCostModel makes the tenant's estimates use the default cost model.
+func noCostModel(pg *testutil.MockPostgresStore) {
+	pg.On("GetJobCostModel", mock.Anything, fixedTenantID, mock.Anything).
+		Return(nil, fmt.Errorf("postgres: job cost model not found")).Maybe()
+}
+
+func decodeEstimate(t *testing.T, w *httptest.ResponseRecorder) domain.JobEstimate {
+	t.Helper()
+	var est domain.JobEstimate
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&est))
+	return est
+}
+
+func TestEstimateAnalysis(t *testing.T) {
+	estimate := func(pg *testutil.MockPostgresStore, body string) *httptest.ResponseRecorder {
+		h := NewAnalysisHandlers(pg, nil, nil, nil, nil)
+		h.SetJobEstimator(worker.NewJobEstimator(pg, nil))
+		return newTestRequest(http.MethodPost, "/api/v1/analyses/estimate").
+			tenant(fixedTenantID.String()).rawBody(body).serve(h.EstimateAnalysis())
+	}
+
+	t.Run("uploaded file", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		noTenantRecord(pg)
+		noCostModel(pg)
+		pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).
+			Return(&domain.LogFile{ID: fixedFileID, SizeBytes: 100 << 20, SourceType: domain.LogSourceJVMGC}, nil)
+
+		w := estimate(pg, `{"file_id":"`+fixedFileID.String()+`"}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		est := decodeEstimate(t, w)
+		assert.Equal(t, int64(100<<20), est.SizeBytes)
+		assert.Equal(t, domain.LogSourceJVMGC, est.SourceType)
+		assert.Equal(t, domain.CostModelDefault, est.Model)
+		assert.Positive(t, est.ProcessingMS)
+		assert.Positive(t, est.StorageBytes)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("size and source type", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		noTenantRecord(pg)
+		noCostModel(pg)
+
+		w := estimate(pg, `{"size_bytes":`+fmt.Sprint(int64(5)<<30)+`}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		est := decodeEstimate(t, w)
+		assert.Equal(t, domain.LogSourceARServer, est.SourceType)
+		assert.Equal(t, []string{"max_file_bytes"}, est.QuotaExceeded, "above the upload limit")
+	})
+
+	t.Run("sandbox quotas", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		noCostModel(pg)
+		pg.On("GetTenant", mock.Anything, fixedTenantID).Return(&domain.Tenant{ID: fixedTenantID, Sandbox: true}, nil)
+		pg.On("CountJobs", mock.Anything, fixedTenantID).Return(domain.SandboxMaxAnalyses, nil)
+
+		w := estimate(pg, `{"size_bytes":1048576,"source_type":"tomcat_access"}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"max_analyses"}, decodeEstimate(t, w).QuotaExceeded)
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		noTenantRecord(pg)
+		noCostModel(pg)
+
+		w := estimate(pg, `{"size_bytes":1048576,"sampling":{"rate":10}}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, 10, decodeEstimate(t, w).SampleRate)
+	})
+
+	for name, tc := range map[string]struct {
+		body   string
+		status int
+	}{
+		"no file or size":     {`{}`, http.StatusBadRequest},
+		"invalid file_id":     {`{"file_id":"nope"}`, http.StatusBadRequest},
+		"unknown source type": {`{"size_bytes":10,"source_type":"syslog"}`, http.StatusBadRequest},
+		"sample rate too low": {`{"size_bytes":10,"sampling":{"rate":1}}`, http.StatusBadRequest},
+		"invalid priority":    {`{"size_bytes":10,"priority":"urgent"}`, http.StatusBadRequest},
+		"invalid JSON":        {`{`, http.StatusBadRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			w := estimate(pg, tc.body)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("file not found", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).Return(nil, errors.New("postgres: log file not found"))
+		w := estimate(pg, `{"file_id":"`+fixedFileID.String()+`"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("estimates not enabled", func(t *testing.T) {
+		h := NewAnalysisHandlers(new(testutil.MockPostgresStore), nil, nil, nil, nil)
+		w := newTestRequest(http.MethodPost, "/api/v1/analyses/estimate").
+			tenant(fixedTenantID.String()).rawBody(`{"size_bytes":10}`).serve(h.EstimateAnalysis())
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, api.ErrCodeServiceUnavail, decodeError(t, w).Code)
+	})
+
+	t.Run("missing tenant", func(t *testing.T) {
+		h := NewAnalysisHandlers(new(testutil.MockPostgresStore), nil, nil, nil, nil)
+		w := newTestRequest(http.MethodPost, "/api/v1/analyses/estimate").rawBody(`{}`).serve(h.EstimateAnalysis())
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// TestCreateAnalysis_StoresEstimate checks that a new job is created with
+// its cost estimate, and is still created when the estimate fails.
+func TestCreateAnalysis_StoresEstimate(t *testing.T) {
+	for name, modelErr := range map[string]error{
+		"estimated":       fmt.Errorf("postgres: job cost model not found"),
+		"estimate failed": errors.New("connection refused"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			noTenantRecord(pg)
+			ns := new(testutil.MockNATSStreamer)
+			pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).
+				Return(&domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: 1 << 20}, nil)
+			pg.On("GetJobCostModel", mock.Anything, fixedTenantID, domain.LogSourceARServer).Return(nil, modelErr)
+			var created *domain.AnalysisJob
+			pg.On("CreateJob", mock.Anything, mock.AnythingOfType("*domain.AnalysisJob")).
+				Run(func(args mock.Arguments) { created = args.Get(1).(*domain.AnalysisJob) }).Return(nil)
+			ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.Anything).Return(nil)
+
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
+			h.SetJobEstimator(worker.NewJobEstimator(pg, nil))
+			w := newTestRequest(http.MethodPost, "/api/v1/analysis").
+				tenant(fixedTenantID.String()).rawBody(`{"file_id":"` + fixedFileID.String() + `"}`).serve(h.CreateAnalysis())
+
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+			require.NotNil(t, created)
+			if name == "estimated" {
+				require.NotNil(t, created.Estimate)
+				assert.Equal(t, int64(1<<20), created.Estimate.SizeBytes)
+				assert.Equal(t, domain.CostModelDefault, created.Estimate.Model)
+			} else {
+				assert.Nil(t, created.Estimate)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/ticketing"
+)
+
+// maxLinksPerAnalysis caps the links of one analysis.
+const maxLinksPerAnalysis = 20
+
+// analysisLinkRequest is the body of a link create request.
+type analysisLinkRequest struct {
+	Type        domain.AnalysisLinkType `json:"type"`
+	ExternalKey string                  `json:"external_key"`
+	Title       string                  `json:"title"`
+}
+
+// ticketingIntegrationRequest is the body of an integration update. The
+// token is required: it is never returned, so that clients cannot send the
+// stored one back. Enabled defaults to true.
+type ticketingIntegrationRequest struct {
+	BaseURL  string `json:"base_url"`
+	Username string `json:"username"`
+	Token    string `json:"token"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// AnalysisLinkHandlers provides HTTP handlers for the links between
+// analyses and Jira issues, ServiceNow records or plain URLs, and for the
+// tenant integrations that validate and sync them.
+type AnalysisLinkHandlers struct {
+	pg      storage.PostgresStore
+	keyring *ticketing.Keyring
+	http    *http.Client
+}
+
+// NewAnalysisLinkHandlers creates the analysis link handlers. Without a
+// keyring integrations cannot be configured and links are not validated.
+// httpClient may be nil.
+func NewAnalysisLinkHandlers(pg storage.PostgresStore, keyring *ticketing.Keyring, httpClient *http.Client) *AnalysisLinkHandlers {
+	return &AnalysisLinkHandlers{pg: pg, keyring: keyring, http: httpClient}
+}
+
+// job resolves the job_id path variable to a job of the tenant, writing
+// the error response when it cannot.
+func (h *AnalysisLinkHandlers) job(w http.ResponseWriter, r *http.Request, tid uuid.UUID) (*domain.AnalysisJob, bool) {
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return nil, false
+	}
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			slog.Error("failed to retrieve job", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return nil, false
+	}
+	return job, true
+}
+
+// integrationType parses the type path variable, writing the error
+// response when it names no ticketing system.
+func integrationType(w http.ResponseWriter, r *http.Request) (domain.AnalysisLinkType, bool) {
+	t := domain.AnalysisLinkType(mux.Vars(r)["type"])
+	if t != domain.AnalysisLinkJira && t != domain.AnalysisLinkServiceNow {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "type must be one of jira, servicenow")
+		return "", false
+	}
+	return t, true
+}
+
+// ListLinks handles GET /api/v1/analysis/{job_id}/links.
+func (h *AnalysisLinkHandlers) ListLinks() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		job, ok := h.job(w, r, tid)
+		if !ok {
+			return
+		}
+
+		links, err := h.pg.ListAnalysisLinks(r.Context(), tid, job.ID)
+		if err != nil {
+			slog.Error("failed to list analysis links", "job_id", job.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list analysis links")
+			return
+		}
+		if links == nil {
+			links = []domain.AnalysisLink{}
+		}
+		fillLinkURLs(r.Context(), h.pg, tid, links)
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"links": links,
+		})
+	})
+}
+
+// CreateLink handles POST /api/v1/analysis/{job_id}/links. When the tenant
+// has an enabled integration with the link's system, the key must name an
+// existing record and the link starts with its status; when the system
+// cannot be reached the link is created with status unknown.
+func (h *AnalysisLinkHandlers) CreateLink() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		job, ok := h.job(w, r, tid)
+		if !ok {
+			return
+		}
+
+		var req analysisLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		key, err := ticketing.NormalizeKey(req.Type, req.ExternalKey)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		link := &domain.AnalysisLink{
+			TenantID:    tid,
+			JobID:       job.ID,
+			Type:        req.Type,
+			ExternalKey: key,
+			Title:       strings.TrimSpace(req.Title),
+			Status:      domain.LinkStatusUnknown,
+			CreatedBy:   middleware.GetUserID(r.Context()),
+		}
+
+		existing, err := h.pg.ListAnalysisLinks(r.Context(), tid, job.ID)
+		if err == nil && len(existing) >= maxLinksPerAnalysis {
+			api.Error(w, http.StatusConflict, api.ErrCodeConflict, "maximum links per analysis reached (20)")
+			return
+		}
+
+		if req.Type != domain.AnalysisLinkURL {
+			integration := h.enabledIntegration(r.Context(), tid, req.Type)
+			if integration != nil {
+				if err := h.lookup(r.Context(), integration, link); errors.Is(err, ticketing.ErrNotFound) {
+					api.Error(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest, key+" does not exist in "+string(req.Type))
+					return
+				}
+				link.URL = ticketing.LinkURL(link.Type, integration.BaseURL, link.ExternalKey)
+			}
+		} else {
+			link.URL = link.ExternalKey
+		}
+
+		if err := h.pg.CreateAnalysisLink(r.Context(), link); err != nil {
+			if errors.Is(err, storage.ErrAlreadyExists) {
+				api.Error(w, http.StatusConflict, api.ErrCodeConflict, "analysis is already linked to "+key)
+				return
+			}
+			slog.Error("failed to create analysis link", "job_id", job.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create analysis link")
+			return
+		}
+
+		api.JSON(w, http.StatusCreated, link)
+	})
+}
+
+// enabledIntegration returns the tenant's enabled integration with system
+// t, nil without one or without a keyring to open its token.
+func (h *AnalysisLinkHandlers) enabledIntegration(ctx context.Context, tid uuid.UUID, t domain.AnalysisLinkType) *domain.TicketingIntegration {
+	if h.keyring == nil {
+		return nil
+	}
+	integration, err := h.pg.GetTicketingIntegration(ctx, tid, t)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			slog.Warn("failed to retrieve ticketing integration", "tenant_id", tid, "type", t, "error", err)
+		}
+		return nil
+	}
+	if !integration.Enabled {
+		return nil
+	}
+	return integration
+}
+
+// lookup fills the status of link from its record. Only ErrNotFound is
+// returned: any other failure leaves the status unknown, for the sync to
+// retry.
+func (h *AnalysisLinkHandlers) lookup(ctx context.Context, integration *domain.TicketingIntegration, link *domain.AnalysisLink) error {
+	client, err := ticketing.NewClient(integration, h.keyring, h.http)
+	if err != nil {
+		slog.Warn("failed to open ticketing integration", "tenant_id", integration.TenantID, "type", integration.Type, "error", err)
+		return nil
+	}
+	rec, err := client.Lookup(ctx, link.ExternalKey)
+	if err != nil {
+		if errors.Is(err, ticketing.ErrNotFound) {
+			return err
+		}
+		slog.Warn("ticketing lookup failed", "tenant_id", integration.TenantID, "key", link.ExternalKey, "error", err)
+		return nil
+	}
+	now := time.Now().UTC()
+	link.Status = rec.Status
+	link.Summary = rec.Summary
+	link.StatusSyncedAt = &now
+	if link.Title == "" {
+		link.Title = rec.Summary
+	}
+	return nil
+}
+
+// DeleteLink handles DELETE /api/v1/analysis/{job_id}/links/{link_id}.
+func (h *AnalysisLinkHandlers) DeleteLink() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+		linkID, ok := pathID(w, r, "link_id")
+		if !ok {
+			return
+		}
+
+		if err := h.pg.DeleteAnalysisLink(r.Context(), tid, jobID, linkID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis link not found")
+				return
+			}
+			slog.Error("failed to delete analysis link", "link_id", linkID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to delete analysis link")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// GetIntegration handles GET /api/v1/integrations/{type}.
+func (h *AnalysisLinkHandlers) GetIntegration() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok || !requireTenantAdmin(w, r) {
+			return
+		}
+		t, ok := integrationType(w, r)
+		if !ok {
+			return
+		}
+
+		integration, err := h.pg.GetTicketingIntegration(r.Context(), tid, t)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "ticketing integration not configured")
+				return
+			}
+			slog.Error("failed to retrieve ticketing integration", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve ticketing integration")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, integration)
+	})
+}
+
+// PutIntegration handles PUT /api/v1/integrations/{type}, sealing the
+// token before it is stored.
+func (h *AnalysisLinkHandlers) PutIntegration() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok || !requireTenantAdmin(w, r) {
+			return
+		}
+		t, ok := integrationType(w, r)
+		if !ok {
+			return
+		}
+		if h.keyring == nil {
+			api.Error(w, http.StatusServiceUnavailable, api.ErrCodeServiceUnavail, "ticketing integrations require TICKETING_ENCRYPTION_KEY")
+			return
+		}
+
+		var req ticketingIntegrationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		baseURL, err := ticketing.NormalizeBaseURL(req.BaseURL)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+		if req.Token == "" {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "token is required")
+			return
+		}
+		sealed, err := h.keyring.Seal([]byte(req.Token), ticketing.TokenAAD(tid, t))
+		if err != nil {
+			slog.Error("failed to seal ticketing token", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to save ticketing integration")
+			return
+		}
+
+		integration := &domain.TicketingIntegration{
+			TenantID: tid,
+			Type:     t,
+			BaseURL:  baseURL,
+			Username: strings.TrimSpace(req.Username),
+			Sealed:   sealed,
+			Enabled:  req.Enabled == nil || *req.Enabled,
+		}
+		if err := h.pg.UpsertTicketingIntegration(r.Context(), integration); err != nil {
+			slog.Error("failed to save ticketing integration", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to save ticketing integration")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, integration)
+	})
+}
+
+// DeleteIntegration handles DELETE /api/v1/integrations/{type}. Links keep
+// the status they were last synced with.
+func (h *AnalysisLinkHandlers) DeleteIntegration() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok || !requireTenantAdmin(w, r) {
+			return
+		}
+		t, ok := integrationType(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.pg.DeleteTicketingIntegration(r.Context(), tid, t); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "ticketing integration not configured")
+				return
+			}
+			slog.Error("failed to delete ticketing integration", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to delete ticketing integration")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// fillLinkURLs sets where each link of the groups points, reading the base
+// URL of the tenant's integrations once per system. Links of systems
+// without an integration get no URL.
+func fillLinkURLs(ctx context.Context, pg storage.PostgresStore, tid uuid.UUID, groups ...[]domain.AnalysisLink) {
+	baseURLs := map[domain.AnalysisLinkType]string{}
+	for _, links := range groups {
+		for i := range links {
+			l := &links[i]
+			base, seen := baseURLs[l.Type]
+			if !seen && l.Type != domain.AnalysisLinkURL {
+				if integration, err := pg.GetTicketingIntegration(ctx, tid, l.Type); err == nil {
+					base = integration.BaseURL
+				}
+				baseURLs[l.Type] = base
+			}
+			l.URL = ticketing.LinkURL(l.Type, base, l.ExternalKey)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/ticketing"
+)
+
+func testTicketingKeyring(t *testing.T) *ticketing.Keyring {
+	t.Helper()
+	k, err := ticketing.NewKeyring(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	require.NoError(t, err)
+	return k
+}
+
+// jiraIntegration returns the tenant's Jira integration with the instance
+// at baseURL, its token sealed by k.
+func jiraIntegration(t *testing.T, k *ticketing.Keyring, baseURL string) *domain.TicketingIntegration {
+	t.Helper()
+	sealed, err := k.Seal([]byte("api-token"), ticketing.TokenAAD(fixedTenantID, domain.AnalysisLinkJira))
+	require.NoError(t, err)
+	return &domain.TicketingIntegration{
+		TenantID: fixedTenantID, Type: domain.AnalysisLinkJira, BaseURL: baseURL, Sealed: sealed, Enabled: true,
+	}
+}
+
+func TestAnalysisLinks_Create(t *testing.T) {
+	k := testTicketingKeyring(t)
+	jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/api/2/issue/PROJ-42" {
+			_, _ = w.Write([]byte(`{"key":"PROJ-42","fields":{"summary":"Slow submits","status":{"name":"Resolved"}}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer jira.Close()
+
+	request := func(body any) *testRequest {
+		return newTestRequest(http.MethodPost, "/api/v1/analysis/"+fixedJobID.String()+"/links").
+			tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).jsonBody(t, body)
+	}
+	setup := func() *handlerMocks {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		m.pg.On("ListAnalysisLinks", mock.Anything, fixedTenantID, fixedJobID).Return([]domain.AnalysisLink{}, nil).Maybe()
+		return m
+	}
+
+	t.Run("validated against Jira", func(t *testing.T) {
+		m := setup()
+		m.pg.On("GetTicketingIntegration", mock.Anything, fixedTenantID, domain.AnalysisLinkJira).
+			Return(jiraIntegration(t, k, jira.URL), nil)
+		m.pg.On("CreateAnalysisLink", mock.Anything, mock.MatchedBy(func(l *domain.AnalysisLink) bool {
+			return l.ExternalKey == "PROJ-42" && l.Status == "Resolved" && l.StatusSyncedAt != nil
+		})).Return(nil)
+
+		w := request(map[string]string{"type": "jira", "external_key": "proj-42"}).
+			serve(NewAnalysisLinkHandlers(m.pg, k, jira.Client()).CreateLink())
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		var link domain.AnalysisLink
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+		assert.Equal(t, "Slow submits", link.Summary)
+		assert.Equal(t, "Slow submits", link.Title, "the title defaults to the summary")
+		assert.Equal(t, jira.URL+"/browse/PROJ-42", link.URL)
+		m.assertExpectations(t)
+	})
+
+	t.Run("unknown issue is rejected", func(t *testing.T) {
+		m := setup()
+		m.pg.On("GetTicketingIntegration", mock.Anything, fixedTenantID, domain.AnalysisLinkJira).
+			Return(jiraIntegration(t, k, jira.URL), nil)
+
+		w := request(map[string]string{"type": "jira", "external_key": "PROJ-7"}).
+			serve(NewAnalysisLinkHandlers(m.pg, k, jira.Client()).CreateLink())
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		m.pg.AssertNotCalled(t, "CreateAnalysisLink", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unreachable Jira links with status unknown", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		m := setup()
+		m.pg.On("GetTicketingIntegration", mock.Anything, fixedTenantID, domain.AnalysisLinkJira).
+			Return(jiraIntegration(t, k, down.URL), nil)
+		m.pg.On("CreateAnalysisLink", mock.Anything, mock.MatchedBy(func(l *domain.AnalysisLink) bool {
+			return l.Status == domain.LinkStatusUnknown && l.StatusSyncedAt == nil
+		})).Return(nil)
+
+		w := request(map[string]string{"type": "jira", "external_key": "PROJ-42"}).
+			serve(NewAnalysisLinkHandlers(m.pg, k, nil).CreateLink())
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		m.assertExpectations(t)
+	})
+
+	t.Run("without integration", func(t *testing.T) {
+		m := setup()
+		m.pg.On("GetTicketingIntegration", mock.Anything, fixedTenantID, domain.AnalysisLinkServiceNow).
+			Return(nil, errors.New("postgres: ticketing integration not found: servicenow"))
+		m.pg.On("CreateAnalysisLink", mock.Anything, mock.Anything).Return(nil)
+
+		w := request(map[string]string{"type": "servicenow", "external_key": "INC0012345", "title": "Outage"}).
+			serve(NewAnalysisLinkHandlers(m.pg, k, nil).CreateLink())
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		var link domain.AnalysisLink
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+		assert.Equal(t, domain.LinkStatusUnknown, link.Status)
+		assert.Empty(t, link.URL)
+	})
+
+	t.Run("url link", func(t *testing.T) {
+		m := setup()
+		m.pg.On("CreateAnalysisLink", mock.Anything, mock.Anything).Return(nil)
+
+		w := request(map[string]string{"type": "url", "external_key": "https://wiki.example.com/rca"}).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).CreateLink())
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		m.pg.AssertNotCalled(t, "GetTicketingIntegration", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		m := setup()
+		m.pg.On("CreateAnalysisLink", mock.Anything, mock.Anything).Return(storage.ErrAlreadyExists)
+
+		w := request(map[string]string{"type": "url", "external_key": "https://wiki.example.com/rca"}).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).CreateLink())
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		m := setup()
+		w := request(map[string]string{"type": "jira", "external_key": "not a key"}).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).CreateLink())
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, decodeError(t, w).Message, "Jira issue key")
+	})
+}
+
+func TestAnalysisLinks_ListAndDelete(t *testing.T) {
+	linkID := uuid.New()
+
+	t.Run("list fills URLs", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		m.pg.On("ListAnalysisLinks", mock.Anything, fixedTenantID, fixedJobID).Return([]domain.AnalysisLink{
+			{ID: linkID, Type: domain.AnalysisLinkJira, ExternalKey: "PROJ-1", Status: "Open"},
+			{Type: domain.AnalysisLinkJira, ExternalKey: "PROJ-2", Status: "Done"},
+		}, nil)
+		m.pg.On("GetTicketingIntegration", mock.Anything, fixedTenantID, domain.AnalysisLinkJira).
+			Return(&domain.TicketingIntegration{BaseURL: "https://acme.atlassian.net"}, nil).Once()
+
+		w := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/links").
+			tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).ListLinks())
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Links []domain.AnalysisLink `json:"links"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		require.Len(t, body.Links, 2)
+		assert.Equal(t, "https://acme.atlassian.net/browse/PROJ-2", body.Links[1].URL)
+		m.assertExpectations(t)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("DeleteAnalysisLink", mock.Anything, fixedTenantID, fixedJobID, linkID).Return(nil)
+
+		w := newTestRequest(http.MethodDelete, "/api/v1/analysis/"+fixedJobID.String()+"/links/"+linkID.String()).
+			tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String(), "link_id", linkID.String()).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).DeleteLink())
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("delete missing link", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("DeleteAnalysisLink", mock.Anything, fixedTenantID, fixedJobID, linkID).
+			Return(errors.New("postgres: analysis link not found: " + linkID.String()))
+
+		w := newTestRequest(http.MethodDelete, "/api/v1/analysis/"+fixedJobID.String()+"/links/"+linkID.String()).
+			tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String(), "link_id", linkID.String()).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).DeleteLink())
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestTicketingIntegration_Put(t *testing.T) {
+	k := testTicketingKeyring(t)
+	request := func(body any) *testRequest {
+		// Without an organization, the owner of a personal tenant is its
+		// administrator.
+		return newTestRequest(http.MethodPut, "/api/v1/integrations/jira").
+			tenant(fixedTenantID.String()).user(fixedTenantID.String()).vars("type", "jira").jsonBody(t, body)
+	}
+
+	t.Run("seals the token", func(t *testing.T) {
+		m := newHandlerMocks()
+		var stored *domain.TicketingIntegration
+		m.pg.On("UpsertTicketingIntegration", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.TicketingIntegration) }).Return(nil)
+
+		w := request(map[string]string{"base_url": "https://acme.atlassian.net/", "token": "s3cr3t"}).
+			serve(NewAnalysisLinkHandlers(m.pg, k, nil).PutIntegration())
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "s3cr3t")
+		require.NotNil(t, stored)
+		assert.Equal(t, "https://acme.atlassian.net", stored.BaseURL)
+		assert.True(t, stored.Enabled)
+		token, err := k.Open(stored.Sealed, ticketing.TokenAAD(fixedTenantID, domain.AnalysisLinkJira))
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", string(token))
+	})
+
+	t.Run("requires an administrator", func(t *testing.T) {
+		m := newHandlerMocks()
+		w := newTestRequest(http.MethodPut, "/api/v1/integrations/jira").
+			tenant(fixedTenantID.String()).vars("type", "jira").
+			jsonBody(t, map[string]string{"base_url": "https://acme.atlassian.net", "token": "x"}).
+			serve(NewAnalysisLinkHandlers(m.pg, k, nil).PutIntegration())
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("without a keyring", func(t *testing.T) {
+		m := newHandlerMocks()
+		w := request(map[string]string{"base_url": "https://acme.atlassian.net", "token": "x"}).
+			serve(NewAnalysisLinkHandlers(m.pg, nil, nil).PutIntegration())
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		m := newHandlerMocks()
+		h := NewAnalysisLinkHandlers(m.pg, k, nil).PutIntegration()
+
+		w := request(map[string]string{"base_url": "ftp://acme", "token": "x"}).serve(h)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = request(map[string]string{"base_url": "https://acme.atlassian.net"}).serve(h)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = newTestRequest(http.MethodPut, "/api/v1/integrations/github").
+			tenant(fixedTenantID.String()).user(fixedTenantID.String()).vars("type", "github").
+			jsonBody(t, map[string]string{"base_url": "https://github.com", "token": "x"}).serve(h)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
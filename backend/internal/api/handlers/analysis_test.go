@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,7 +19,10 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
 )
 
 // ---------------------------------------------------------------------------
@@ -40,6 +44,13 @@ func injectAuth(req *http.Request, tenantID string) *http.Request {
 
 // decodeError is a small helper that decodes an api.ErrorResponse from the
 // recorder body, failing the test on any decode error.
+// noTenantRecord has the tenant lookup of pg find no record, leaving the
+// configured quotas in force.
+func noTenantRecord(pg *testutil.MockPostgresStore) {
+	pg.On("GetTenant", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("postgres: tenant not found")).Maybe()
+}
+
 func decodeError(t *testing.T, w *httptest.ResponseRecorder) api.ErrorResponse {
 	t.Helper()
 	var resp api.ErrorResponse
@@ -113,6 +124,14 @@ func TestCreateAnalysis(t *testing.T) {
 			wantErrCode:    api.ErrCodeInvalidRequest,
 			wantErrContain: "file_id",
 		},
+		{
+			name:           "unknown output_format returns 400",
+			tenantID:       fixedTenantID.String(),
+			body:           `{"file_id":"` + fixedFileID.String() + `","jar_flags":{"output_format":"yaml"}}`,
+			wantStatus:     http.StatusBadRequest,
+			wantErrCode:    api.ErrCodeInvalidRequest,
+			wantErrContain: "output_format",
+		},
 		{
 			name:           "invalid tenant_id format returns 400",
 			tenantID:       "not-a-uuid",
@@ -271,6 +290,7 @@ func TestCreateAnalysis(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			pg := new(testutil.MockPostgresStore)
+			noTenantRecord(pg)
 			ns := new(testutil.MockNATSStreamer)
 
 			if tc.setupPG != nil {
@@ -280,7 +300,7 @@ func TestCreateAnalysis(t *testing.T) {
 				tc.setupNATS(ns)
 			}
 
-			h := NewAnalysisHandlers(pg, ns)
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis", bytes.NewBufferString(tc.body))
 			req.Header.Set("Content-Type", "application/json")
 
@@ -323,6 +343,7 @@ func TestCreateAnalysis(t *testing.T) {
 // with a MatchedBy assertion on the job passed to CreateJob.
 func TestCreateAnalysis_JARFlagsTopNDefault(t *testing.T) {
 	pg := new(testutil.MockPostgresStore)
+	noTenantRecord(pg)
 	ns := new(testutil.MockNATSStreamer)
 
 	validFile := &domain.LogFile{
@@ -346,7 +367,7 @@ func TestCreateAnalysis_JARFlagsTopNDefault(t *testing.T) {
 		mock.AnythingOfType("domain.AnalysisJob")).
 		Return(nil)
 
-	h := NewAnalysisHandlers(pg, ns)
+	h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 	body := `{"file_id":"` + fixedFileID.String() + `"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -364,6 +385,7 @@ func TestCreateAnalysis_JARFlagsTopNDefault(t *testing.T) {
 // value is respected and not overwritten.
 func TestCreateAnalysis_JARFlagsExplicitTopN(t *testing.T) {
 	pg := new(testutil.MockPostgresStore)
+	noTenantRecord(pg)
 	ns := new(testutil.MockNATSStreamer)
 
 	validFile := &domain.LogFile{
@@ -383,7 +405,7 @@ func TestCreateAnalysis_JARFlagsExplicitTopN(t *testing.T) {
 		mock.AnythingOfType("domain.AnalysisJob")).
 		Return(nil)
 
-	h := NewAnalysisHandlers(pg, ns)
+	h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 	body := `{"file_id":"` + fixedFileID.String() + `","jar_flags":{"top_n":200}}`
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -397,6 +419,67 @@ func TestCreateAnalysis_JARFlagsExplicitTopN(t *testing.T) {
 	ns.AssertExpectations(t)
 }
 
+// TestCreateAnalysis_Priority verifies that the priority defaults by file
+// size and that only administrators may raise it.
+func TestCreateAnalysis_Priority(t *testing.T) {
+	tests := []struct {
+		name         string
+		sizeBytes    int64
+		priority     string // priority field of the request, if any
+		admin        bool
+		wantStatus   int
+		wantPriority domain.JobPriority
+	}{
+		{"small file is interactive", 50 << 20, "", false, http.StatusCreated, domain.JobPriorityInteractive},
+		{"medium file is normal", 500 << 20, "", false, http.StatusCreated, domain.JobPriorityNormal},
+		{"large file is batch", 5 << 30, "", false, http.StatusCreated, domain.JobPriorityBatch},
+		{"users may lower the priority", 50 << 20, "batch", false, http.StatusCreated, domain.JobPriorityBatch},
+		{"users may keep the default", 500 << 20, "normal", false, http.StatusCreated, domain.JobPriorityNormal},
+		{"users may not raise the priority", 5 << 30, "interactive", false, http.StatusForbidden, ""},
+		{"administrators may raise the priority", 5 << 30, "interactive", true, http.StatusCreated, domain.JobPriorityInteractive},
+		{"unknown priority", 50 << 20, "urgent", true, http.StatusBadRequest, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			noTenantRecord(pg)
+			ns := new(testutil.MockNATSStreamer)
+			file := &domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: tc.sizeBytes}
+			pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).Return(file, nil).Maybe()
+			if tc.wantPriority != "" {
+				pg.On("CreateJob", mock.Anything, mock.MatchedBy(func(job *domain.AnalysisJob) bool {
+					return job.Priority == tc.wantPriority
+				})).Return(nil)
+				ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.MatchedBy(func(job domain.AnalysisJob) bool {
+					return job.Priority == tc.wantPriority
+				})).Return(nil)
+			}
+
+			var admins []string
+			if tc.admin {
+				admins = []string{"test-user"}
+			}
+			h := NewAnalysisHandlers(pg, ns, nil, nil, admins)
+			body, err := json.Marshal(analysisJobCreateRequest{FileID: fixedFileID.String(), Priority: domain.JobPriority(tc.priority)})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis", bytes.NewReader(body))
+			req = injectAuth(req, fixedTenantID.String())
+
+			w := httptest.NewRecorder()
+			h.CreateAnalysis().ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantPriority != "" {
+				var job domain.AnalysisJob
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&job))
+				assert.Equal(t, tc.wantPriority, job.Priority)
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
+
 // ---------------------------------------------------------------------------
 // ListAnalyses tests
 // ---------------------------------------------------------------------------
@@ -462,6 +545,8 @@ func TestListAnalyses(t *testing.T) {
 			setupPG: func(pg *testutil.MockPostgresStore) {
 				pg.On("ListJobs", mock.Anything, fixedTenantID).
 					Return(sampleJobs, nil)
+				pg.On("ListAnalysisLinksByJobs", mock.Anything, fixedTenantID, mock.Anything).
+					Return(map[uuid.UUID][]domain.AnalysisLink{}, nil)
 			},
 			wantStatus:   http.StatusOK,
 			wantJobCount: 2,
@@ -487,7 +572,7 @@ func TestListAnalyses(t *testing.T) {
 				tc.setupPG(pg)
 			}
 
-			h := NewAnalysisHandlers(pg, ns)
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis", nil)
 
 			if tc.tenantID != "" {
@@ -636,7 +721,7 @@ func TestGetAnalysis(t *testing.T) {
 				tc.setupPG(pg)
 			}
 
-			h := NewAnalysisHandlers(pg, ns)
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+tc.jobIDVar, nil)
 
 			if tc.tenantID != "" {
@@ -706,7 +791,7 @@ func TestGetAnalysis_ReturnsAllJobStatuses(t *testing.T) {
 			pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
 				Return(job, nil)
 
-			h := NewAnalysisHandlers(pg, ns)
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String(), nil)
 			req = injectAuth(req, fixedTenantID.String())
 			req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String()})
@@ -725,6 +810,301 @@ func TestGetAnalysis_ReturnsAllJobStatuses(t *testing.T) {
 	}
 }
 
+func TestGetAnalysis_QueueEstimate(t *testing.T) {
+	now := time.Now().UTC()
+	queued := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusQueued, CreatedAt: now}
+	snap := &domain.JobQueueSnapshot{
+		Queued: []domain.QueuedJob{
+			{ID: uuid.New(), Priority: domain.JobPriorityInteractive, SizeBytes: 10 << 20, CreatedAt: now},
+			{ID: fixedJobID, Priority: domain.JobPriorityNormal, SizeBytes: 200 << 20, CreatedAt: now.Add(-time.Minute)},
+		},
+		MSPerMB: 100,
+	}
+
+	get := func(t *testing.T, pg *testutil.MockPostgresStore) map[string]interface{} {
+		t.Helper()
+		h := NewAnalysisHandlers(pg, new(testutil.MockNATSStreamer), worker.NewQueueEstimator(pg, 1, worker.PriorityStrict, 0), nil, nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String(), nil)
+		req = injectAuth(req, fixedTenantID.String())
+		req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String()})
+		w := httptest.NewRecorder()
+		h.GetAnalysis().ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		return body
+	}
+
+	t.Run("queued", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(queued, nil)
+		pg.On("GetJobQueueSnapshot", mock.Anything).Return(snap, nil)
+
+		queue, ok := get(t, pg)["queue"].(map[string]interface{})
+		require.True(t, ok, "queued jobs carry their queue position")
+		assert.Equal(t, float64(2), queue["position"], "the interactive job goes first")
+		assert.Equal(t, float64(1), queue["jobs_ahead"])
+		assert.Equal(t, float64(1000), queue["estimated_wait_ms"])
+		assert.NotEmpty(t, queue["estimated_start_at"])
+	})
+
+	t.Run("estimate failure still returns the job", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(queued, nil)
+		pg.On("GetJobQueueSnapshot", mock.Anything).Return(nil, fmt.Errorf("connection refused"))
+
+		assert.NotContains(t, get(t, pg), "queue")
+	})
+
+	t.Run("running", func(t *testing.T) {
+		running := *queued
+		running.Status = domain.JobStatusParsing
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(&running, nil)
+
+		assert.NotContains(t, get(t, pg), "queue")
+		pg.AssertNotCalled(t, "GetJobQueueSnapshot", mock.Anything)
+	})
+}
+
+func TestGetAnalysis_SurfacesFileIntegrity(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	ns := new(testutil.MockNATSStreamer)
+
+	offset := int64(4096)
+	code := domain.IntegrityBinaryContent
+	msg := "corrupt log file: the file contains binary data"
+	job := &domain.AnalysisJob{
+		ID:           fixedJobID,
+		TenantID:     fixedTenantID,
+		FileID:       fixedFileID,
+		Status:       domain.JobStatusFailed,
+		ErrorMessage: &msg,
+		ErrorCode:    &code,
+		Integrity: &domain.FileIntegrity{
+			SizeBytes:    8192,
+			SampledBytes: 8192,
+			Issues: []domain.IntegrityIssue{
+				{Code: code, Severity: domain.IntegrityFatal, Message: "binary data", Offset: &offset, Count: 4096},
+			},
+		},
+	}
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+
+	h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String(), nil)
+	req = injectAuth(req, fixedTenantID.String())
+	req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String()})
+
+	w := httptest.NewRecorder()
+	h.GetAnalysis().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result domain.AnalysisJob
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	require.NotNil(t, result.ErrorCode)
+	assert.Equal(t, code, *result.ErrorCode)
+	require.NotNil(t, result.Integrity)
+	require.NotNil(t, result.Integrity.Fatal())
+	require.NotNil(t, result.Integrity.Fatal().Offset)
+	assert.Equal(t, offset, *result.Integrity.Fatal().Offset)
+}
+
+// ---------------------------------------------------------------------------
+// Job event log
+// ---------------------------------------------------------------------------
+
+func TestCreateAnalysis_Sampling(t *testing.T) {
+	tests := []struct {
+		name         string
+		sampling     *analysisSamplingRequest
+		wantStatus   int
+		wantSampling *domain.Sampling
+	}{
+		{"every entry by default", nil, http.StatusCreated, nil},
+		{"default slow threshold", &analysisSamplingRequest{Rate: 20}, http.StatusCreated,
+			&domain.Sampling{Rate: 20, SlowThresholdMS: domain.DefaultSamplingSlowThresholdMS}},
+		{"explicit slow threshold", &analysisSamplingRequest{Rate: 5, SlowThresholdMS: 250}, http.StatusCreated,
+			&domain.Sampling{Rate: 5, SlowThresholdMS: 250}},
+		{"rate too low", &analysisSamplingRequest{Rate: 1}, http.StatusBadRequest, nil},
+		{"rate too high", &analysisSamplingRequest{Rate: domain.MaxSampleRate + 1}, http.StatusBadRequest, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			noTenantRecord(pg)
+			ns := new(testutil.MockNATSStreamer)
+			file := &domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: 1024}
+			pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).Return(file, nil).Maybe()
+			if tc.wantStatus == http.StatusCreated {
+				pg.On("CreateJob", mock.Anything, mock.MatchedBy(func(job *domain.AnalysisJob) bool {
+					return assert.ObjectsAreEqual(tc.wantSampling, job.Sampling)
+				})).Return(nil)
+				ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.MatchedBy(func(job domain.AnalysisJob) bool {
+					return assert.ObjectsAreEqual(tc.wantSampling, job.Sampling)
+				})).Return(nil)
+			}
+
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
+			body, err := json.Marshal(analysisJobCreateRequest{FileID: fixedFileID.String(), Sampling: tc.sampling})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis", bytes.NewReader(body))
+			req = injectAuth(req, fixedTenantID.String())
+
+			w := httptest.NewRecorder()
+			h.CreateAnalysis().ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantStatus == http.StatusCreated {
+				var job domain.AnalysisJob
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&job))
+				assert.Equal(t, tc.wantSampling, job.Sampling)
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateAnalysis_Sandbox(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		expiresAt  time.Time
+		jobs       int
+		wantStatus int
+		wantCode   string
+	}{
+		{"below the analyses limit", future, domain.SandboxMaxAnalyses - 1, http.StatusCreated, ""},
+		{"at the analyses limit", future, domain.SandboxMaxAnalyses, http.StatusForbidden, api.ErrCodeQuotaExceeded},
+		{"expired", past, 0, http.StatusForbidden, api.ErrCodeForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			ns := new(testutil.MockNATSStreamer)
+			m.pg.On("GetTenant", mock.Anything, fixedTenantID).
+				Return(&domain.Tenant{ID: fixedTenantID, Sandbox: true, SandboxExpiresAt: &tc.expiresAt}, nil)
+			m.pg.On("CountJobs", mock.Anything, fixedTenantID).Return(tc.jobs, nil).Maybe()
+			if tc.wantStatus == http.StatusCreated {
+				m.pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).
+					Return(&domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: 1024}, nil)
+				m.pg.On("CreateJob", mock.Anything, mock.MatchedBy(func(job *domain.AnalysisJob) bool {
+					return job.Sandbox
+				})).Return(nil)
+				ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.Anything).Return(nil)
+			}
+
+			rr := newTestRequest(http.MethodPost, "/api/v1/analysis").
+				tenant(fixedTenantID.String()).
+				jsonBody(t, map[string]string{"file_id": fixedFileID.String()}).
+				serve(NewAnalysisHandlers(m.pg, ns, nil, nil, nil).CreateAnalysis())
+
+			require.Equal(t, tc.wantStatus, rr.Code, rr.Body.String())
+			if tc.wantCode != "" {
+				assert.Equal(t, tc.wantCode, decodeError(t, rr).Code)
+			}
+			m.assertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateAnalysis_RecordsJobEvents(t *testing.T) {
+	m := newHandlerMocks()
+	noTenantRecord(m.pg)
+	ns := new(testutil.MockNATSStreamer)
+	m.pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).
+		Return(&domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: 2048}, nil)
+	m.pg.On("CreateJob", mock.Anything, mock.Anything).Return(nil)
+	ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.Anything).Return(nil)
+
+	var events []domain.JobEvent
+	m.pg.On("AppendJobEvents", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { events = append(events, args.Get(1).([]domain.JobEvent)...) }).
+		Return(0, nil)
+	appender := jobevents.NewAppender(m.pg, domain.JobEventSourceAPI, 0, 0, time.Hour)
+
+	rr := newTestRequest(http.MethodPost, "/api/v1/analysis").
+		tenant(fixedTenantID.String()).
+		jsonBody(t, map[string]string{"file_id": fixedFileID.String()}).
+		serve(NewAnalysisHandlers(m.pg, ns, nil, appender, nil).CreateAnalysis())
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	appender.Run(ctx)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, domain.JobEventCreated, events[0].Kind)
+	assert.Equal(t, domain.JobEventSourceAPI, events[0].Source)
+	assert.Equal(t, domain.JobEventPublish, events[1].Kind)
+	assert.Equal(t, "job_submit", events[1].Metadata["subject"])
+	assert.Equal(t, events[0].JobID, events[1].JobID)
+	m.assertExpectations(t)
+}
+
+func TestJobEvents(t *testing.T) {
+	t0 := time.Now().UTC().Add(-10 * time.Minute)
+	events := []domain.JobEvent{
+		{ID: 2, JobID: fixedJobID, Kind: domain.JobEventStageStarted, Stage: "jar", OccurredAt: t0.Add(2 * time.Second)},
+		{ID: 1, JobID: fixedJobID, Kind: domain.JobEventCreated, OccurredAt: t0},
+	}
+
+	t.Run("running job", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsingJob(fixedTenantID, fixedJobID), nil)
+		m.pg.On("ListJobEvents", mock.Anything, fixedTenantID, fixedJobID).Return(events, nil)
+
+		rr := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/events").
+			tenant(fixedTenantID.String()).
+			vars("job_id", fixedJobID.String()).
+			serve(NewAnalysisHandlers(m.pg, nil, nil, nil, nil).JobEvents())
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp struct {
+			Status domain.JobStatus          `json:"status"`
+			Events []domain.JobTimelineEvent `json:"events"`
+			IdleMS *int64                    `json:"idle_ms"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		assert.Equal(t, domain.JobStatusParsing, resp.Status)
+		require.Len(t, resp.Events, 2)
+		assert.Equal(t, domain.JobEventCreated, resp.Events[0].Kind)
+		assert.Equal(t, domain.JobEventStageStarted, resp.Events[1].Kind)
+		assert.Equal(t, int64(2000), resp.Events[1].SincePreviousMS)
+		require.NotNil(t, resp.IdleMS, "running jobs report the time since their last event")
+		assert.GreaterOrEqual(t, *resp.IdleMS, int64(9*time.Minute/time.Millisecond))
+		m.assertExpectations(t)
+	})
+
+	t.Run("completed job has no idle time", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		m.pg.On("ListJobEvents", mock.Anything, fixedTenantID, fixedJobID).Return(events, nil)
+
+		rr := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/events").
+			tenant(fixedTenantID.String()).
+			vars("job_id", fixedJobID.String()).
+			serve(NewAnalysisHandlers(m.pg, nil, nil, nil, nil).JobEvents())
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "idle_ms")
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+
+		rr := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/events").
+			tenant(fixedTenantID.String()).
+			vars("job_id", fixedJobID.String()).
+			serve(NewAnalysisHandlers(m.pg, nil, nil, nil, nil).JobEvents())
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Response content-type verification
 // ---------------------------------------------------------------------------
@@ -792,7 +1172,7 @@ func TestHandlers_ReturnJSONContentType(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			h := NewAnalysisHandlers(nil, nil)
+			h := NewAnalysisHandlers(nil, nil, nil, nil, nil)
 			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
 			if tc.tenantID != "" {
 				req = injectAuth(req, tc.tenantID)
@@ -817,6 +1197,83 @@ func TestHandlers_ReturnJSONContentType(t *testing.T) {
 func TestNewAnalysisHandlers_ReturnsNonNil(t *testing.T) {
 	pg := new(testutil.MockPostgresStore)
 	ns := new(testutil.MockNATSStreamer)
-	h := NewAnalysisHandlers(pg, ns)
+	h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
 	require.NotNil(t, h, "NewAnalysisHandlers should return a non-nil handler")
 }
+
+// fakeInlineAnalyzer analyses files up to maxBytes inline with err.
+type fakeInlineAnalyzer struct {
+	maxBytes int64
+	err      error
+	runs     int
+}
+
+func (f *fakeInlineAnalyzer) Eligible(sizeBytes int64) bool { return sizeBytes <= f.maxBytes }
+
+func (f *fakeInlineAnalyzer) Run(ctx context.Context, job domain.AnalysisJob) error {
+	f.runs++
+	return f.err
+}
+
+func TestCreateAnalysis_Inline(t *testing.T) {
+	deadline := fmt.Errorf("%w: deadline of 15s exceeded", worker.ErrInlineFallback)
+	tests := []struct {
+		name       string
+		body       string
+		sizeBytes  int64
+		auto       bool
+		runErr     error
+		wantStatus int
+		wantRuns   int
+		wantQueued bool
+	}{
+		{name: "small file completes inline", body: `{"file_id":"%s"}`, sizeBytes: 1024, auto: true,
+			wantStatus: http.StatusCreated, wantRuns: 1},
+		{name: "deadline falls back to the queue", body: `{"file_id":"%s"}`, sizeBytes: 1024, auto: true, runErr: deadline,
+			wantStatus: http.StatusAccepted, wantRuns: 1, wantQueued: true},
+		{name: "no free slot falls back to the queue", body: `{"file_id":"%s","sync":true}`, sizeBytes: 1024, runErr: worker.ErrInlineBusy,
+			wantStatus: http.StatusAccepted, wantRuns: 1, wantQueued: true},
+		{name: "sync false queues", body: `{"file_id":"%s","sync":false}`, sizeBytes: 1024, auto: true,
+			wantStatus: http.StatusCreated, wantQueued: true},
+		{name: "large file queues", body: `{"file_id":"%s","sync":true}`, sizeBytes: 1 << 30, auto: true,
+			wantStatus: http.StatusCreated, wantQueued: true},
+		{name: "not automatic without sync", body: `{"file_id":"%s"}`, sizeBytes: 1024,
+			wantStatus: http.StatusCreated, wantQueued: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			noTenantRecord(pg)
+			ns := new(testutil.MockNATSStreamer)
+			pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).
+				Return(&domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: tc.sizeBytes}, nil)
+			pg.On("CreateJob", mock.Anything, mock.AnythingOfType("*domain.AnalysisJob")).Return(nil)
+			if tc.wantQueued {
+				ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil).Once()
+			} else {
+				pg.On("GetJob", mock.MatchedBy(storage.UsesPrimary), fixedTenantID, mock.AnythingOfType("uuid.UUID")).
+					Return(&domain.AnalysisJob{ID: fixedJobID, Status: domain.JobStatusComplete}, nil)
+			}
+			inline := &fakeInlineAnalyzer{maxBytes: 4096, err: tc.runErr}
+			h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
+			h.SetInlineAnalyzer(inline, tc.auto)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis", bytes.NewBufferString(fmt.Sprintf(tc.body, fixedFileID)))
+			req = injectAuth(req, fixedTenantID.String())
+			w := httptest.NewRecorder()
+			h.CreateAnalysis().ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			assert.Equal(t, tc.wantRuns, inline.runs)
+			var job domain.AnalysisJob
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&job))
+			if tc.wantQueued {
+				assert.Equal(t, domain.JobStatusQueued, job.Status)
+			} else {
+				assert.Equal(t, domain.JobStatusComplete, job.Status)
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/localauth"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// LoginHandler serves POST /api/v1/auth/login, the sign-in of local users
+// when AUTH_PROVIDER=local.
+type LoginHandler struct {
+	pg     storage.PostgresStore
+	issuer *localauth.Issuer
+}
+
+func NewLoginHandler(pg storage.PostgresStore, issuer *localauth.Issuer) *LoginHandler {
+	return &LoginHandler{pg: pg, issuer: issuer}
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserID    string    `json:"user_id"`
+	TenantID  string    `json:"tenant_id"`
+	Role      string    `json:"role"`
+}
+
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid request body")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || req.Password == "" {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "email and password are required")
+		return
+	}
+
+	user, err := localauth.Authenticate(r.Context(), h.pg, req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, localauth.ErrInvalidCredentials) {
+			slog.Warn("local sign-in failed", "remote_addr", r.RemoteAddr)
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "invalid email or password")
+			return
+		}
+		slog.Error("local sign-in failed", "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to sign in")
+		return
+	}
+
+	token, exp, err := h.issuer.Issue(user)
+	if err != nil {
+		slog.Error("issue local token failed", "user_id", user.ID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to sign in")
+		return
+	}
+	if err := h.pg.RecordLocalLogin(r.Context(), user.ID, time.Now().UTC()); err != nil {
+		slog.Warn("record local login failed", "user_id", user.ID, "error", err)
+	}
+
+	api.JSON(w, http.StatusOK, loginResponse{
+		Token:     token,
+		ExpiresAt: exp,
+		UserID:    user.ID.String(),
+		TenantID:  user.TenantID.String(),
+		Role:      string(user.Role),
+	})
+}
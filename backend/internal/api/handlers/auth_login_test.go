@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/localauth"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestLoginHandler(t *testing.T) {
+	hash, err := localauth.HashPassword("correct-horse-battery")
+	require.NoError(t, err)
+	user := &domain.LocalUser{ID: uuid.New(), TenantID: fixedTenantID, Email: "ops@example.com", PasswordHash: hash, Role: domain.LocalUserAdmin}
+	issuer, err := localauth.NewIssuer([]string{strings.Repeat("k", 32)}, time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+	}{
+		{
+			name: "valid credentials return a token",
+			body: `{"email":"ops@example.com","password":"correct-horse-battery"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetLocalUserByEmail", mock.Anything, "ops@example.com").Return(user, nil)
+				pg.On("RecordLocalLogin", mock.Anything, user.ID, mock.Anything).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "wrong password",
+			body: `{"email":"ops@example.com","password":"wrong-password-123"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetLocalUserByEmail", mock.Anything, "ops@example.com").Return(user, nil)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown user",
+			body: `{"email":"nobody@example.com","password":"correct-horse-battery"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetLocalUserByEmail", mock.Anything, "nobody@example.com").
+					Return(nil, errors.New("postgres: local user not found: nobody@example.com"))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing password",
+			body:       `{"email":"ops@example.com"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid body",
+			body:       `{`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "store failure",
+			body: `{"email":"ops@example.com","password":"correct-horse-battery"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetLocalUserByEmail", mock.Anything, "ops@example.com").Return(nil, errors.New("connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+			h := NewLoginHandler(pg, issuer)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			pg.AssertExpectations(t)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var resp loginResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, fixedTenantID.String(), resp.TenantID)
+			id, err := issuer.Verify(resp.Token)
+			require.NoError(t, err)
+			assert.Equal(t, user.ID.String(), id.UserID)
+			assert.Equal(t, fixedTenantID.String(), id.TenantID)
+			assert.Equal(t, "org:admin", id.OrgRole)
+		})
+	}
+}
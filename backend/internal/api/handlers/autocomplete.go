@@ -42,6 +42,8 @@ var KnownFields = []AutocompleteField{
 	{Name: "rpc_id", Description: "RPC call identifier"},
 	{Name: "api_code", Description: "AR API code"},
 	{Name: "form", Description: "AR form name"},
+	{Name: "client", Description: "Client program that issued the API call"},
+	{Name: "client_ip", Description: "IP address the API call came from"},
 	{Name: "operation", Description: "Operation type (GET, SET, CREATE, DELETE)"},
 	{Name: "request_id", Description: "Request identifier"},
 	{Name: "sql_table", Description: "SQL table name"},
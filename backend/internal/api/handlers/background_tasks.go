@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// BackgroundTaskHandlers serves the worker's background tasks to
+// operators. The tasks are registered by the workers as they start.
+type BackgroundTaskHandlers struct {
+	pg storage.PostgresStore
+}
+
+// NewBackgroundTaskHandlers creates the background task handlers.
+func NewBackgroundTaskHandlers(pg storage.PostgresStore) *BackgroundTaskHandlers {
+	return &BackgroundTaskHandlers{pg: pg}
+}
+
+// ListTasks handles GET /api/v1/admin/tasks: every registered task with
+// its schedule, next run, lock and last run.
+func (h *BackgroundTaskHandlers) ListTasks() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tasks, err := h.pg.ListBackgroundTasks(r.Context())
+		if err != nil {
+			slog.Error("failed to list background tasks", "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list background tasks")
+			return
+		}
+		if tasks == nil {
+			tasks = []domain.BackgroundTask{}
+		}
+		noStore(w)
+		api.JSON(w, http.StatusOK, map[string]any{"tasks": tasks})
+	})
+}
+
+// RunNow handles POST /api/v1/admin/tasks/{name}/run-now. The task runs at
+// the next poll of a worker or, when a run is in flight, right after it;
+// a request made while another is pending is merged into it.
+func (h *BackgroundTaskHandlers) RunNow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		task, err := h.pg.RequestBackgroundTaskRun(r.Context(), name, middleware.GetUserID(r.Context()))
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "background task not found")
+				return
+			}
+			slog.Error("failed to request background task run", "task", name, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to request background task run")
+			return
+		}
+		api.JSON(w, http.StatusAccepted, task)
+	})
+}
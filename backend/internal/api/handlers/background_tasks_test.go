@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestBackgroundTaskHandlers_ListTasks(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []domain.BackgroundTask{
+		{
+			Name: "retention", Schedule: "@every 1h0m0s", TimeoutSeconds: 1800, NextRunAt: now.Add(time.Hour),
+			LastRun: &domain.BackgroundTaskRun{ID: 7, TaskName: "retention", Trigger: domain.TaskTriggerSchedule, WorkerID: "w1",
+				StartedAt: now, FinishedAt: now.Add(2 * time.Second), DurationMS: 2000, Outcome: domain.TaskOutcomeError, Error: "clickhouse unavailable"},
+		},
+		{Name: "trash-purge", Schedule: "@every 1h0m0s", TimeoutSeconds: 1800, NextRunAt: now, Running: true},
+	}
+
+	t.Run("lists tasks", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListBackgroundTasks", mock.Anything).Return(tasks, nil)
+
+		w := newTestRequest(http.MethodGet, "/api/v1/admin/tasks").serve(NewBackgroundTaskHandlers(pg).ListTasks())
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got struct {
+			Tasks []domain.BackgroundTask `json:"tasks"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		require.Len(t, got.Tasks, 2)
+		require.NotNil(t, got.Tasks[0].LastRun)
+		assert.Equal(t, domain.TaskOutcomeError, got.Tasks[0].LastRun.Outcome)
+		assert.Equal(t, "clickhouse unavailable", got.Tasks[0].LastRun.Error)
+		assert.True(t, got.Tasks[1].Running)
+		assert.Nil(t, got.Tasks[1].LastRun)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("none registered", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListBackgroundTasks", mock.Anything).Return(nil, nil)
+
+		w := newTestRequest(http.MethodGet, "/api/v1/admin/tasks").serve(NewBackgroundTaskHandlers(pg).ListTasks())
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"tasks":[]}`, w.Body.String())
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListBackgroundTasks", mock.Anything).Return(nil, errors.New("connection refused"))
+
+		w := newTestRequest(http.MethodGet, "/api/v1/admin/tasks").serve(NewBackgroundTaskHandlers(pg).ListTasks())
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestBackgroundTaskHandlers_RunNow(t *testing.T) {
+	requested := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("requests a run", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("RequestBackgroundTaskRun", mock.Anything, "retention", "admin-1").
+			Return(&domain.BackgroundTask{Name: "retention", RunRequestedAt: &requested, RunRequestedBy: "admin-1"}, nil)
+
+		w := newTestRequest(http.MethodPost, "/api/v1/admin/tasks/retention/run-now").
+			tenant(fixedTenantID.String()).
+			user("admin-1").
+			vars("name", "retention").
+			serve(NewBackgroundTaskHandlers(pg).RunNow())
+
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var got domain.BackgroundTask
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, "admin-1", got.RunRequestedBy)
+		require.NotNil(t, got.RunRequestedAt)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("unknown task", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("RequestBackgroundTaskRun", mock.Anything, "nope", mock.Anything).
+			Return(nil, fmt.Errorf("postgres: background task not found: nope"))
+
+		w := newTestRequest(http.MethodPost, "/api/v1/admin/tasks/nope/run-now").
+			vars("name", "nope").
+			serve(NewBackgroundTaskHandlers(pg).RunNow())
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/bootstrap"
+)
+
+// bootstrapRequest is the optional body of POST /api/v1/admin/bootstrap.
+type bootstrapRequest struct {
+	RunSample bool `json:"run_sample"`
+}
+
+// Bootstrapper runs the checks of an installation. bootstrap.Bootstrapper
+// implements it.
+type Bootstrapper interface {
+	Run(ctx context.Context, opts bootstrap.Options) *bootstrap.Report
+}
+
+// BootstrapHandler serves POST /api/v1/admin/bootstrap: every dependency
+// of the installation checked in one call, with what to do about each
+// failure, and with run_sample a sample log analysed end to end. The
+// report is returned with 200 whatever the checks found; its status tells
+// whether the installation is ready.
+//
+// One run at a time: the sample analysis writes to the stores, and two
+// runs would report on each other's probes.
+type BootstrapHandler struct {
+	bootstrapper Bootstrapper
+	running      sync.Mutex
+}
+
+// NewBootstrapHandler creates the handler.
+func NewBootstrapHandler(b Bootstrapper) *BootstrapHandler {
+	return &BootstrapHandler{bootstrapper: b}
+}
+
+func (h *BootstrapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req bootstrapRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+
+	if !h.running.TryLock() {
+		api.Error(w, http.StatusConflict, api.ErrCodeConflict, "a bootstrap run is already in progress")
+		return
+	}
+	defer h.running.Unlock()
+
+	if req.RunSample {
+		// The sample runs the JAR; the server's write timeout would cut
+		// the response off.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	}
+	report := h.bootstrapper.Run(r.Context(), bootstrap.Options{RunSample: req.RunSample})
+	slog.Info("bootstrap run", "user_id", middleware.GetUserID(r.Context()), "status", report.Status,
+		"run_sample", req.RunSample, "duration_ms", report.DurationMS)
+	noStore(w)
+	api.JSON(w, http.StatusOK, report)
+}
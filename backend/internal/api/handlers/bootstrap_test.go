@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/bootstrap"
+)
+
+// fakeBootstrapper records the options of its runs and, with block set,
+// holds each run until block is closed.
+type fakeBootstrapper struct {
+	opts    []bootstrap.Options
+	started chan struct{}
+	block   chan struct{}
+}
+
+func (f *fakeBootstrapper) Run(ctx context.Context, opts bootstrap.Options) *bootstrap.Report {
+	f.opts = append(f.opts, opts)
+	if f.block != nil {
+		close(f.started)
+		<-f.block
+	}
+	return &bootstrap.Report{Status: bootstrap.StatusFail, Checks: []bootstrap.Result{
+		{Name: bootstrap.CheckPostgres, Status: bootstrap.StatusPass, Message: "connected"},
+		{Name: bootstrap.CheckJAR, Status: bootstrap.StatusFail, Message: "JAR_PATH does not exist", Remediation: "set JAR_PATH"},
+	}}
+}
+
+func TestBootstrapHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantSample []bool
+	}{
+		{name: "no body", wantStatus: http.StatusOK, wantSample: []bool{false}},
+		{name: "with sample", body: `{"run_sample":true}`, wantStatus: http.StatusOK, wantSample: []bool{true}},
+		{name: "invalid body", body: `{"run_sample":`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &fakeBootstrapper{}
+			w := newTestRequest(http.MethodPost, "/api/v1/admin/bootstrap").tenant(fixedTenantID.String()).
+				rawBody(tc.body).serve(NewBootstrapHandler(b))
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			var gotSample []bool
+			for _, o := range b.opts {
+				gotSample = append(gotSample, o.RunSample)
+			}
+			assert.Equal(t, tc.wantSample, gotSample)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var report bootstrap.Report
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+			assert.Equal(t, bootstrap.StatusFail, report.Status, "a failed check is reported, not an error")
+			require.Len(t, report.Checks, 2)
+			assert.Equal(t, "set JAR_PATH", report.Checks[1].Remediation)
+		})
+	}
+}
+
+func TestBootstrapHandler_OneRunAtATime(t *testing.T) {
+	b := &fakeBootstrapper{started: make(chan struct{}), block: make(chan struct{})}
+	h := NewBootstrapHandler(b)
+	done := make(chan int)
+	go func() {
+		done <- newTestRequest(http.MethodPost, "/api/v1/admin/bootstrap").tenant(fixedTenantID.String()).serve(h).Code
+	}()
+	<-b.started
+
+	w := newTestRequest(http.MethodPost, "/api/v1/admin/bootstrap").tenant(fixedTenantID.String()).serve(h)
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, api.ErrCodeConflict, decodeError(t, w).Code)
+
+	close(b.block)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Len(t, b.opts, 1)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+	"github.com/OmarEhab007/RemedyIQ/backend/pkg/client"
+)
+
+// The Go client mirrors the request and response types of the handlers
+// rather than importing them. These tests keep the mirrors in step: a field
+// added to, renamed in or dropped from either side fails here.
+
+// jsonFields returns the JSON names of the fields of v, embedded structs
+// flattened.
+func jsonFields(v any) []string {
+	var names []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" || !f.IsExported() && !f.Anonymous {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				walk(ft)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			names = append(names, name)
+		}
+	}
+	walk(reflect.TypeOf(v))
+	sort.Strings(names)
+	return names
+}
+
+func TestClientContract_Requests(t *testing.T) {
+	tests := []struct {
+		name           string
+		server, client any
+	}{
+		{"create analysis", analysisJobCreateRequest{}, client.CreateAnalysisRequest{}},
+		{"sampling", analysisSamplingRequest{}, client.Sampling{}},
+		{"estimate", analysisEstimateRequest{}, client.EstimateRequest{}},
+		{"investigation update", investigationUpdateRequest{}, client.InvestigationUpdate{}},
+		{"search export", searchExportCreateRequest{}, client.CreateExportRequest{}},
+		{"threshold rule", thresholdRuleRequest{}, client.ThresholdRuleRequest{}},
+		{"ingestion filter", ingestionFilterRuleRequest{}, client.IngestionFilterRequest{}},
+		{"dry run", ingestionFilterDryRunRequest{}, client.DryRunRequest{}},
+		{"analysis link", analysisLinkRequest{}, client.AnalysisLinkRequest{}},
+		{"saved search", createSavedSearchRequest{}, client.SavedSearchRequest{}},
+		{"search query", storage.SearchQuery{}, client.SearchQuery{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, jsonFields(tt.server), jsonFields(tt.client))
+		})
+	}
+}
+
+func TestClientContract_Responses(t *testing.T) {
+	tests := []struct {
+		name           string
+		server, client any
+	}{
+		{"investigation", investigationResponse{}, client.InvestigationResponse{}},
+		{"dry run result", ingestionFilterDryRunResult{}, client.DryRunResult{}},
+		{"search response", SearchResponse{}, client.SearchResponse{}},
+		{"search hit", SearchHit{}, client.SearchHit{}},
+		{"facet", FacetEntry{}, client.FacetEntry{}},
+		{"time range", ResolvedTimeRange{}, client.ResolvedTimeRange{}},
+		{"suggestion", search.Suggestion{}, client.Suggestion{}},
+		{"interpretation", search.QueryInterpretation{}, client.QueryInterpretation{}},
+		{"interpreted term", search.InterpretedTerm{}, client.InterpretedTerm{}},
+		{"trace entry", traceEntry{}, client.TraceEntry{}},
+		{"job progress", streaming.JobProgress{}, client.JobProgress{}},
+		{"dashboard section", streaming.DashboardSectionPayload{}, client.DashboardSection{}},
+		{"dashboard complete", streaming.DashboardCompletePayload{}, client.DashboardComplete{}},
+		{"dashboard section error", streaming.DashboardSectionError{}, client.DashboardSectionError{}},
+		{"stream error", streaming.ErrorPayload{}, client.StreamError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, jsonFields(tt.server), jsonFields(tt.client))
+		})
+	}
+}
+
+func TestClientContract_Trace(t *testing.T) {
+	// The trace response is the trace ID and entries, then the trailer.
+	want := append(jsonFields(traceTrailer{}), "entries", "trace_id")
+	sort.Strings(want)
+	assert.Equal(t, want, jsonFields(client.Trace{}))
+}
+
+func TestClientContract_MessageTypes(t *testing.T) {
+	assert.Equal(t, []string{
+		streaming.MsgTypeJobProgress, streaming.MsgTypeJobComplete, streaming.MsgTypeLiveTailEntry,
+		streaming.MsgTypeInvestigation, streaming.MsgTypeDashboardSection, streaming.MsgTypeDashboardComplete,
+		streaming.MsgTypeError, streaming.MsgTypePong,
+	}, []string{
+		client.EventJobProgress, client.EventJobComplete, client.EventLiveTailEntry,
+		client.EventInvestigation, client.EventDashboardSection, client.EventDashboardComplete,
+		client.EventError, client.EventPong,
+	})
+}
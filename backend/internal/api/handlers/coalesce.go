@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// readFlights coalesces the dashboard reads that miss the cache: while a
+// section is computed for a (tenant, job, section, parameters) key,
+// identical requests wait for that computation rather than starting their
+// own, so that a team opening the same analysis at once runs its
+// ClickHouse queries once.
+var readFlights = newFlightGroup()
+
+// flightGroup runs one computation per key at a time and shares its result
+// with every caller asking for the key meanwhile.
+type flightGroup struct {
+	g singleflight.Group
+
+	computed  atomic.Int64
+	coalesced atomic.Int64
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{}
+}
+
+// do returns the result of fn for key, computed by this call or shared
+// with the call already computing it. fn runs detached from ctx, so that
+// the leader's client going away does not fail the waiters; a caller whose
+// ctx ends stops waiting with ctx's error. Errors reach every waiter and
+// are not kept: the next call for the key computes again.
+func (f *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	led := false
+	ch := f.g.DoChan(key, func() (any, error) {
+		led = true
+		f.computed.Add(1)
+		return fn(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-ch:
+		if !led {
+			f.coalesced.Add(1)
+		}
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// coalesce runs fn through readFlights under key, the cache key of the
+// read it computes.
+func coalesce[T any](ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	v, err := readFlights.do(ctx, key, func(ctx context.Context) (any, error) { return fn(ctx) })
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// CoalescingStats counts the dashboard reads that missed the cache since
+// the process started: those that computed their section and those that
+// shared the computation of an identical read already in flight.
+type CoalescingStats struct {
+	Computed  int64 `json:"computed"`
+	Coalesced int64 `json:"coalesced"`
+}
+
+func (f *flightGroup) stats() CoalescingStats {
+	return CoalescingStats{Computed: f.computed.Load(), Coalesced: f.coalesced.Load()}
+}
+
+// ReadCoalescingStats returns the coalescing counts of dashboard reads.
+func ReadCoalescingStats() CoalescingStats {
+	return readFlights.stats()
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// serveHeatmapConcurrently sends n identical heatmap requests at once,
+// holding ClickHouse until every request has missed the cache, and returns
+// the response statuses and how many times ClickHouse was queried.
+func serveHeatmapConcurrently(t *testing.T, n int, resp *domain.ErrorHeatmapResponse, chErr error) ([]int, int64) {
+	t.Helper()
+	m := newHandlerMocks()
+	baseKey := "tenant:" + fixedTenantID.String() + ":dashboard:" + fixedJobID.String()
+	cacheKey := baseKey + ":exc-heatmap:auto:10"
+
+	var misses sync.WaitGroup
+	misses.Add(n)
+	release := make(chan struct{})
+	var calls atomic.Int64
+
+	m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+	m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+	m.redis.On("Get", mock.Anything, cacheKey).Run(func(mock.Arguments) { misses.Done() }).Return("", errors.New("cache miss"))
+	if chErr == nil {
+		m.redis.On("Set", mock.Anything, cacheKey, mock.Anything, sectionCacheTTL).Return(nil).Once()
+		m.redis.On("Set", mock.Anything, cacheKey+staleSuffix, mock.Anything, staleSectionTTL).Return(nil).Once()
+	}
+	m.ch.On("GetErrorHeatmap", mock.Anything, fixedTenantID.String(), fixedJobID.String(), "auto", 10).
+		Run(func(mock.Arguments) {
+			calls.Add(1)
+			<-release
+		}).Return(resp, chErr)
+
+	h := NewErrorHeatmapHandler(m.pg, m.ch, m.redis)
+	statuses := make([]int, n)
+	var done sync.WaitGroup
+	for i := range n {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			statuses[i] = newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/dashboard/exceptions/heatmap").
+				tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).serve(h).Code
+		}()
+	}
+
+	misses.Wait()
+	// Every request has missed the cache; give the last of them the moment
+	// it takes to join the flight before the leader completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	done.Wait()
+
+	m.assertExpectations(t)
+	return statuses, calls.Load()
+}
+
+func TestCoalesce_ConcurrentIdenticalReadsQueryOnce(t *testing.T) {
+	before := ReadCoalescingStats()
+	resp := &domain.ErrorHeatmapResponse{BucketSize: "5m"}
+
+	statuses, calls := serveHeatmapConcurrently(t, 50, resp, nil)
+
+	assert.Equal(t, int64(1), calls, "identical concurrent reads share one ClickHouse query")
+	for _, code := range statuses {
+		assert.Equal(t, http.StatusOK, code)
+	}
+	after := ReadCoalescingStats()
+	assert.Equal(t, int64(1), after.Computed-before.Computed)
+	assert.Equal(t, int64(49), after.Coalesced-before.Coalesced)
+}
+
+func TestCoalesce_ErrorReachesEveryWaiterAndIsNotCached(t *testing.T) {
+	statuses, calls := serveHeatmapConcurrently(t, 20, nil, errors.New("boom"))
+
+	assert.Equal(t, int64(1), calls)
+	for _, code := range statuses {
+		assert.Equal(t, http.StatusInternalServerError, code)
+	}
+
+	// The failure is forgotten with its flight: the next read computes again.
+	_, calls = serveHeatmapConcurrently(t, 1, nil, errors.New("boom"))
+	assert.Equal(t, int64(1), calls)
+}
+
+func TestFlightGroup_WaiterStopsWithItsContext(t *testing.T) {
+	f := newFlightGroup()
+	started, release := make(chan struct{}), make(chan struct{})
+	leader := make(chan error, 1)
+	go func() {
+		_, err := f.do(context.Background(), "k", func(ctx context.Context) (any, error) {
+			close(started)
+			<-release
+			return "v", ctx.Err()
+		})
+		leader <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.do(ctx, "k", func(context.Context) (any, error) {
+		t.Error("a waiter must not compute")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-leader, "the leader is not cancelled with a waiter")
+	assert.Equal(t, CoalescingStats{Computed: 1}, f.stats())
+}
+
+func TestFlightGroup_LeaderDetachedFromItsContext(t *testing.T) {
+	f := newFlightGroup()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The computation runs detached, so a cancelled leader only stops
+	// waiting; the flight itself sees no cancellation.
+	seen := make(chan error, 1)
+	_, _ = f.do(ctx, "k", func(ctx context.Context) (any, error) {
+		seen <- ctx.Err()
+		return nil, nil
+	})
+	assert.NoError(t, <-seen)
+}
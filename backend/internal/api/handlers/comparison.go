@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/compare"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+// comparisonExportRequest is the body of POST /api/v1/analyses/compare/export.
+type comparisonExportRequest struct {
+	BaselineJobID  string   `json:"baseline_job_id"`
+	CandidateJobID string   `json:"candidate_job_id"`
+	Sections       []string `json:"sections"`
+	Format         string   `json:"format"`
+
+	// RequireConsistentCapture refuses the export when the captures were
+	// taken differently.
+	RequireConsistentCapture bool `json:"require_consistent_capture"`
+}
+
+// comparisonExportFormats maps the requested format to the export format.
+var comparisonExportFormats = map[string]string{
+	"html": domain.ExportFormatComparisonHTML,
+	"zip":  domain.ExportFormatComparisonZIP,
+}
+
+// ComparisonHandlers provides the HTTP handlers comparing two analyses of a
+// tenant. Comparisons are returned as JSON or, for change records, exported
+// by the worker as an HTML report or a zip of per-section CSV files that is
+// downloaded through GET /api/v1/exports/{export_id}.
+type ComparisonHandlers struct {
+	pg        storage.PostgresStore
+	comparer  *compare.Comparer
+	nats      streaming.NATSStreamer
+	retention time.Duration
+
+	stamps
+}
+
+// NewComparisonHandlers creates the comparison handlers. retention is how
+// long exported reports are kept before cleanup.
+func NewComparisonHandlers(pg storage.PostgresStore, ch storage.ClickHouseStore, nats streaming.NATSStreamer, retention time.Duration) *ComparisonHandlers {
+	return &ComparisonHandlers{pg: pg, comparer: compare.NewComparer(pg, ch), nats: nats, retention: retention}
+}
+
+// Compare handles GET /api/v1/analyses/compare?baseline=&candidate=&sections=.
+// sections is a comma-separated list; by default every section is compared.
+// Comparisons of captures taken differently carry their capture drift, or
+// are refused with require_consistent_capture=true.
+func (h *ComparisonHandlers) Compare() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		q := r.URL.Query()
+		var names []string
+		if s := q.Get("sections"); s != "" {
+			names = strings.Split(s, ",")
+		}
+		bid, ok := queryID(w, r, "baseline")
+		if !ok {
+			return
+		}
+		cid, ok := queryID(w, r, "candidate")
+		if !ok {
+			return
+		}
+		if bid == uuid.Nil || cid == uuid.Nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "baseline and candidate are required")
+			return
+		}
+		baseline, candidate, sections, ok := h.resolve(w, r, tid, bid, cid, names)
+		if !ok {
+			return
+		}
+		if q.Get("require_consistent_capture") == "true" && !consistentCaptures(w, baseline, candidate) {
+			return
+		}
+
+		cmp, err := h.comparer.Compare(r.Context(), tid, baseline, candidate, sections)
+		if err != nil {
+			slog.Error("failed to compare analyses",
+				"baseline_job_id", baseline.ID, "candidate_job_id", candidate.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to compare analyses")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, cmp)
+	})
+}
+
+// CreateExport handles POST /api/v1/analyses/compare/export. The export is
+// generated asynchronously; its status and download URL are available from
+// GET /api/v1/exports/{export_id}.
+func (h *ComparisonHandlers) CreateExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		var req comparisonExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		if req.Format == "" {
+			req.Format = "html"
+		}
+		format, ok := comparisonExportFormats[req.Format]
+		if !ok {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "format must be one of html, zip")
+			return
+		}
+
+		bid, ok := bodyID(w, "baseline_job_id", req.BaselineJobID)
+		if !ok {
+			return
+		}
+		cid, ok := bodyID(w, "candidate_job_id", req.CandidateJobID)
+		if !ok {
+			return
+		}
+		baseline, candidate, sections, ok := h.resolve(w, r, tid, bid, cid, req.Sections)
+		if !ok {
+			return
+		}
+		if req.RequireConsistentCapture && !consistentCaptures(w, baseline, candidate) {
+			return
+		}
+
+		query, err := json.Marshal(domain.ComparisonExportQuery{
+			BaselineJobID:  baseline.ID,
+			CandidateJobID: candidate.ID,
+			Sections:       sections,
+		})
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid query")
+			return
+		}
+
+		export := &domain.SearchExport{
+			ID:        h.newID(),
+			TenantID:  tid,
+			JobID:     candidate.ID,
+			UserID:    middleware.GetUserID(r.Context()),
+			Status:    domain.ExportStatusQueued,
+			Format:    format,
+			Query:     query,
+			ExpiresAt: h.now().Add(h.retention),
+		}
+
+		if err := h.pg.CreateSearchExport(r.Context(), export); err != nil {
+			slog.Error("failed to create comparison export", "candidate_job_id", candidate.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create export")
+			return
+		}
+
+		if err := h.nats.PublishExportSubmit(r.Context(), tid.String(), *export); err != nil {
+			errMsg := "failed to queue export: " + err.Error()
+			if updateErr := h.pg.UpdateSearchExportStatus(r.Context(), tid, export.ID, domain.ExportStatusFailed, &errMsg); updateErr != nil {
+				slog.Error("failed to update export status after NATS publish failure",
+					"export_id", export.ID, "tenant_id", tid, "error", updateErr)
+			}
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to queue export")
+			return
+		}
+
+		api.JSON(w, http.StatusAccepted, export)
+	})
+}
+
+// CaptureBaseline handles PUT and DELETE /api/v1/analyses/{job_id}/capture-baseline.
+// PUT designates the analysis as the tenant's baseline capture, replacing
+// the previous baseline; later analyses are checked for capture drift
+// against it rather than against the previous analysis. DELETE clears the
+// designation. The analysis must be complete.
+func (h *ComparisonHandlers) CaptureBaseline() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		job, err := h.pg.GetJob(r.Context(), tid, jobID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+		if job.Status != domain.JobStatusComplete {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+			return
+		}
+
+		baseline := r.Method == http.MethodPut
+		if err := h.pg.SetCaptureBaseline(r.Context(), tid, jobID, baseline); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+				return
+			}
+			slog.Error("failed to set capture baseline", "job_id", jobID, "baseline", baseline, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to set capture baseline")
+			return
+		}
+		job.CaptureBaseline = baseline
+
+		api.JSON(w, http.StatusOK, job)
+	})
+}
+
+// resolve validates the compared jobs and sections and loads both jobs,
+// writing the error response when they cannot be compared.
+func (h *ComparisonHandlers) resolve(w http.ResponseWriter, r *http.Request, tid, bid, cid uuid.UUID, names []string) (*domain.AnalysisJob, *domain.AnalysisJob, []domain.ComparisonSection, bool) {
+	if bid == cid {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "baseline and candidate must be different analyses")
+		return nil, nil, nil, false
+	}
+	sections, err := compare.ParseSections(names)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return nil, nil, nil, false
+	}
+
+	var jobs [2]*domain.AnalysisJob
+	for i, id := range []uuid.UUID{bid, cid} {
+		job, err := h.pg.GetJob(r.Context(), tid, id)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found: "+id.String())
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return nil, nil, nil, false
+		}
+		if job.Status != domain.JobStatusComplete {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete: "+id.String())
+			return nil, nil, nil, false
+		}
+		jobs[i] = job
+	}
+	return jobs[0], jobs[1], sections, true
+}
+
+// consistentCaptures reports whether the captures of baseline and
+// candidate were taken alike, refusing the comparison with the differences
+// when they were not.
+func consistentCaptures(w http.ResponseWriter, baseline, candidate *domain.AnalysisJob) bool {
+	drift := compare.CaptureDrift(compare.ProfileOf(baseline), compare.ProfileOf(candidate), "baseline capture")
+	if len(drift) == 0 {
+		return true
+	}
+	api.ErrorWithDetails(w, http.StatusConflict, api.ErrCodeCaptureDrift,
+		"the captures were taken differently and are not comparable", map[string]any{"capture_drift": drift})
+	return false
+}
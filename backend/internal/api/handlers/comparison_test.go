@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var fixedBaselineJobID = uuid.MustParse("00000000-0000-0000-0000-000000000013")
+
+// withProfile returns a copy of job captured as files files with every log
+// type logged.
+func withProfile(job *domain.AnalysisJob, files int) *domain.AnalysisJob {
+	c := *job
+	c.CaptureProfile = &domain.CaptureProfile{API: true, SQL: true, Filter: true, Escalation: true, DurationMS: 3_600_000, FileCount: files}
+	return &c
+}
+
+func TestComparisonHandlers_CreateExport(t *testing.T) {
+	baseline := &domain.AnalysisJob{ID: fixedBaselineJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	candidate := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	parsing := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusParsing}
+	body := func(extra string) string {
+		return fmt.Sprintf(`{"baseline_job_id":%q,"candidate_job_id":%q%s}`, fixedBaselineJobID, fixedJobID, extra)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "queues zip export of selected sections",
+			body: body(`,"format":"zip","sections":["queues","forms"]`),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(baseline, nil)
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(candidate, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					var q domain.ComparisonExportQuery
+					if err := json.Unmarshal(e.Query, &q); err != nil {
+						return false
+					}
+					return e.TenantID == fixedTenantID && e.JobID == fixedJobID &&
+						e.Format == domain.ExportFormatComparisonZIP && e.UserID == "test-user" &&
+						q.BaselineJobID == fixedBaselineJobID && q.CandidateJobID == fixedJobID &&
+						assert.ObjectsAreEqual([]domain.ComparisonSection{domain.ComparisonForms, domain.ComparisonQueues}, q.Sections)
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.SearchExport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, domain.ExportStatusQueued, resp.Status)
+			},
+		},
+		{
+			name: "defaults to html with every section",
+			body: body(""),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(baseline, nil)
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(candidate, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					var q domain.ComparisonExportQuery
+					return json.Unmarshal(e.Query, &q) == nil &&
+						e.Format == domain.ExportFormatComparisonHTML && len(q.Sections) == len(domain.ComparisonSections)
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "same job on both sides returns 400",
+			body:       fmt.Sprintf(`{"baseline_job_id":%q,"candidate_job_id":%q}`, fixedJobID, fixedJobID),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid baseline returns 400",
+			body:       fmt.Sprintf(`{"baseline_job_id":"nope","candidate_job_id":%q}`, fixedJobID),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown section returns 400",
+			body:       body(`,"sections":["threads"]`),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, `unknown section "threads"`)
+			},
+		},
+		{
+			name:       "unsupported format returns 400",
+			body:       body(`,"format":"pdf"`),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unknown baseline returns 404",
+			body: body(""),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(nil, fmt.Errorf("postgres: job not found: %s", fixedBaselineJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "incomplete candidate returns 409",
+			body: body(""),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(baseline, nil)
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsing, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "drifted captures refused when consistency is required",
+			body: body(`,"require_consistent_capture":true`),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(withProfile(baseline, 4), nil)
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(withProfile(candidate, 2), nil)
+			},
+			wantStatus: http.StatusConflict,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, api.ErrCodeCaptureDrift, decodeError(t, w).Code)
+			},
+		},
+		{
+			name: "publish failure marks export failed",
+			body: body(""),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(baseline, nil)
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(candidate, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.AnythingOfType("*domain.SearchExport")).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(errors.New("nats down"))
+				pg.On("UpdateSearchExportStatus", mock.Anything, fixedTenantID, mock.AnythingOfType("uuid.UUID"), domain.ExportStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			ns := new(testutil.MockNATSStreamer)
+			tc.setupMocks(pg, ns)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analyses/compare/export", bytes.NewBufferString(tc.body))
+			req = injectAuth(req, fixedTenantID.String())
+
+			w := httptest.NewRecorder()
+			NewComparisonHandlers(pg, ch, ns, testExportRetention).CreateExport().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
+
+func TestComparisonHandlers_Compare(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	baseline := &domain.AnalysisJob{ID: fixedBaselineJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	candidate := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(baseline, nil)
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(candidate, nil)
+	pg.On("GetLogFile", mock.Anything, fixedTenantID, mock.Anything).Return(&domain.LogFile{Filename: "arserver.log"}, nil)
+	ch.On("GetGaps", mock.Anything, fixedTenantID.String(), fixedBaselineJobID.String()).Return(&domain.GapsResponse{
+		QueueHealth: []domain.QueueHealthSummary{{Queue: "Fast", TotalCalls: 10}},
+	}, nil)
+	ch.On("GetGaps", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(&domain.GapsResponse{
+		QueueHealth: []domain.QueueHealthSummary{{Queue: "Fast", TotalCalls: 30}},
+	}, nil)
+
+	url := fmt.Sprintf("/api/v1/analyses/compare?baseline=%s&candidate=%s&sections=queues", fixedBaselineJobID, fixedJobID)
+	req := injectAuth(httptest.NewRequest(http.MethodGet, url, nil), fixedTenantID.String())
+	w := httptest.NewRecorder()
+	NewComparisonHandlers(pg, ch, new(testutil.MockNATSStreamer), testExportRetention).Compare().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp domain.AnalysisComparison
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []domain.ComparisonSection{domain.ComparisonQueues}, resp.Sections)
+	require.Len(t, resp.Queues, 1)
+	assert.Equal(t, int64(10), resp.Queues[0].Baseline.TotalCalls)
+	assert.Equal(t, int64(30), resp.Queues[0].Candidate.TotalCalls)
+	assert.Nil(t, resp.Health)
+	ch.AssertExpectations(t)
+}
+
+func TestComparisonHandlers_CompareCaptureDrift(t *testing.T) {
+	baseline := withProfile(&domain.AnalysisJob{ID: fixedBaselineJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}, 4)
+	candidate := withProfile(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}, 4)
+	candidate.CaptureProfile.SQL = false
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		pg := new(testutil.MockPostgresStore)
+		ch := new(testutil.MockClickHouseStore)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(baseline, nil)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(candidate, nil)
+		pg.On("GetLogFile", mock.Anything, fixedTenantID, mock.Anything).Return(&domain.LogFile{Filename: "arserver.log"}, nil).Maybe()
+		ch.On("GetGaps", mock.Anything, fixedTenantID.String(), mock.Anything).Return(&domain.GapsResponse{}, nil).Maybe()
+
+		url := fmt.Sprintf("/api/v1/analyses/compare?baseline=%s&candidate=%s&sections=queues%s", fixedBaselineJobID, fixedJobID, query)
+		req := injectAuth(httptest.NewRequest(http.MethodGet, url, nil), fixedTenantID.String())
+		w := httptest.NewRecorder()
+		NewComparisonHandlers(pg, ch, new(testutil.MockNATSStreamer), testExportRetention).Compare().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("caveats the comparison", func(t *testing.T) {
+		w := serve("")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp domain.AnalysisComparison
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.Len(t, resp.CaptureDrift, 1)
+		assert.Equal(t, domain.CaptureDriftLogType, resp.CaptureDrift[0].Kind)
+		assert.Equal(t, "SQL logging absent in this capture but present in the baseline capture", resp.CaptureDrift[0].Message)
+	})
+
+	t.Run("refused when consistency is required", func(t *testing.T) {
+		w := serve("&require_consistent_capture=true")
+		require.Equal(t, http.StatusConflict, w.Code)
+		resp := decodeError(t, w)
+		assert.Equal(t, api.ErrCodeCaptureDrift, resp.Code)
+		assert.Contains(t, resp.Details, "capture_drift")
+	})
+}
+
+func TestComparisonHandlers_CaptureBaseline(t *testing.T) {
+	complete := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	parsing := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusParsing}
+
+	tests := []struct {
+		name         string
+		method       string
+		setupMocks   func(pg *testutil.MockPostgresStore)
+		wantStatus   int
+		wantBaseline bool
+	}{
+		{
+			name:   "put designates the baseline",
+			method: http.MethodPut,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(complete, nil)
+				pg.On("SetCaptureBaseline", mock.Anything, fixedTenantID, fixedJobID, true).Return(nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantBaseline: true,
+		},
+		{
+			name:   "delete clears the baseline",
+			method: http.MethodDelete,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(complete, nil)
+				pg.On("SetCaptureBaseline", mock.Anything, fixedTenantID, fixedJobID, false).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "unknown job returns 404",
+			method: http.MethodPut,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "incomplete job returns 409",
+			method: http.MethodPut,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsing, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "store failure returns 500",
+			method: http.MethodPut,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(complete, nil)
+				pg.On("SetCaptureBaseline", mock.Anything, fixedTenantID, fixedJobID, true).Return(errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			req := httptest.NewRequest(tc.method, "/api/v1/analyses/"+fixedJobID.String()+"/capture-baseline", nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+
+			w := httptest.NewRecorder()
+			NewComparisonHandlers(pg, new(testutil.MockClickHouseStore), new(testutil.MockNATSStreamer), testExportRetention).CaptureBaseline().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusOK {
+				var resp domain.AnalysisJob
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, tc.wantBaseline, resp.CaptureBaseline)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/mux"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
@@ -29,9 +28,8 @@ func (h *ConversationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tenantUUID, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant ID")
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -52,15 +50,12 @@ func (h *ConversationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *ConversationsHandler) list(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, userID string) {
-	jobIDStr := r.URL.Query().Get("job_id")
-	if jobIDStr == "" {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "job_id is required")
+	jobID, ok := queryID(w, r, "job_id")
+	if !ok {
 		return
 	}
-
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id")
+	if jobID == uuid.Nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "job_id is required")
 		return
 	}
 
@@ -103,9 +98,8 @@ func (h *ConversationsHandler) create(w http.ResponseWriter, r *http.Request, te
 		return
 	}
 
-	jobID, err := uuid.Parse(req.JobID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id")
+	jobID, ok := bodyID(w, "job_id", req.JobID)
+	if !ok {
 		return
 	}
 
@@ -139,16 +133,13 @@ func (h *ConversationDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	tenantUUID, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant ID")
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
-	conversationIDStr := mux.Vars(r)["id"]
-	conversationID, err := uuid.Parse(conversationIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid conversation ID")
+	conversationID, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
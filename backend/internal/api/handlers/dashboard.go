@@ -1,13 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
@@ -32,16 +32,13 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
 
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+	tid, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -58,24 +55,79 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+		sectionNotComplete(w)
 		return
 	}
 
-	cacheKey := h.redis.TenantKey(tenantID, "dashboard", jobID.String())
-	cached, err := h.redis.Get(r.Context(), cacheKey)
-	if err != nil || cached == "" {
+	// The overlays of the incident group change with the other analyses of
+	// the group, which the job's ETag does not cover.
+	withGroup := includeGroup(r)
+	etag := sectionETag(job, "dashboard")
+	if !withGroup && sectionNotModified(w, r, etag) {
+		return
+	}
+
+	data, err := loadDashboard(r.Context(), h.redis, tenantID, job)
+	if errors.Is(err, errDashboardNotCached) {
 		slog.Error("dashboard data not found in cache", "job_id", jobID, "error", err)
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "dashboard data not available - analysis may need to be re-run")
 		return
 	}
-
-	var data domain.DashboardData
-	if err := json.Unmarshal([]byte(cached), &data); err != nil {
+	if err != nil {
 		slog.Error("failed to unmarshal cached dashboard data", "job_id", jobID, "error", err)
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to parse dashboard data")
 		return
 	}
+	if withGroup {
+		if data.Overlays, err = groupOverlays(r.Context(), h.pg, h.ch, job); err != nil {
+			slog.Error("failed to load incident group overlays", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to load incident group overlays")
+			return
+		}
+	}
 
-	api.JSON(w, http.StatusOK, data)
+	writeSection(w, etag, data, !withGroup)
+}
+
+// errDashboardNotCached is returned by loadDashboard when the job has no
+// cached dashboard.
+var errDashboardNotCached = errors.New("dashboard data not found in cache")
+
+// loadDashboard returns the cached dashboard of a completed job with the
+// job's own details set on it.
+func loadDashboard(ctx context.Context, redis storage.RedisCache, tenantID string, job *domain.AnalysisJob) (*domain.DashboardData, error) {
+	cached, err := redis.Get(ctx, redis.TenantKey(tenantID, "dashboard", job.ID.String()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDashboardNotCached, err)
+	}
+	if cached == "" {
+		return nil, errDashboardNotCached
+	}
+
+	var data domain.DashboardData
+	if err := json.Unmarshal([]byte(cached), &data); err != nil {
+		return nil, fmt.Errorf("parse dashboard data: %w", err)
+	}
+	data.FirstErrorAt = domain.TimestampPtr(job.FirstErrorAt)
+	data.Markers = restartMarkers(job.Restarts)
+	data.FocusWindow = job.FocusWindow
+	data.DataQuality = job.DataQuality
+	data.Reconciliation = job.Reconciliation
+	return &data, nil
+}
+
+// restartMarkers marks the server restarts of a job on the time series,
+// shading their warm-up windows.
+func restartMarkers(restarts []domain.RestartEvent) []domain.TimeSeriesMarker {
+	var markers []domain.TimeSeriesMarker
+	for _, r := range restarts {
+		markers = append(markers, domain.TimeSeriesMarker{
+			Kind:       domain.TimeSeriesMarkerRestart,
+			Timestamp:  r.Timestamp,
+			EndTime:    r.WarmupEnd,
+			Label:      "Server restart",
+			Confidence: r.Confidence,
+		})
+	}
+	return markers
 }
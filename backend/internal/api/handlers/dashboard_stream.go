@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+// DashboardStream gives the WebSocket hub the dashboard sections of a
+// completed job, loaded and decorated as the REST section handlers do.
+type DashboardStream struct {
+	pg storage.PostgresStore
+
+	// sections builds each section for a job, in the order they are
+	// started: the dashboard with its general statistics and time series
+	// first, then the heavier sections.
+	sections []func(tenantID string, job *domain.AnalysisJob) streaming.DashboardSection
+}
+
+// NewDashboardStream creates the dashboard source of the WebSocket hub.
+func NewDashboardStream(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *DashboardStream {
+	return &DashboardStream{
+		pg: pg,
+		sections: []func(string, *domain.AnalysisJob) streaming.DashboardSection{
+			func(tenantID string, job *domain.AnalysisJob) streaming.DashboardSection {
+				return streamSection("dashboard", func(ctx context.Context) (any, bool, error) {
+					data, err := loadDashboard(ctx, redis, tenantID, job)
+					return data, true, err
+				})
+			},
+			NewAggregatesHandler(pg, ch, redis).streamSection,
+			NewExceptionsHandler(pg, ch, redis).streamSection,
+			NewThreadsHandler(pg, ch, redis).streamSection,
+			NewGapsHandler(pg, ch, redis).streamSection,
+			NewFiltersHandler(pg, ch, redis).streamSection,
+			func(tenantID string, job *domain.AnalysisJob) streaming.DashboardSection {
+				return streamSection("queued-calls", func(ctx context.Context) (any, bool, error) {
+					return getOrComputeQueuedCalls(ctx, redis, tenantID, job.ID.String())
+				})
+			},
+			func(tenantID string, job *domain.AnalysisJob) streaming.DashboardSection {
+				return streamSection("logging-activity", func(ctx context.Context) (any, bool, error) {
+					return getOrComputeLoggingActivity(ctx, redis, tenantID, job.ID.String())
+				})
+			},
+			func(tenantID string, job *domain.AnalysisJob) streaming.DashboardSection {
+				return streamSection("file-metadata", func(ctx context.Context) (any, bool, error) {
+					return getOrComputeFileMetadata(ctx, redis, tenantID, job.ID.String())
+				})
+			},
+		},
+	}
+}
+
+// DashboardSections returns the sections of the dashboard of a completed
+// job of the tenant.
+func (s *DashboardStream) DashboardSections(ctx context.Context, tenantID, jobID string) ([]streaming.DashboardSection, error) {
+	tid, err := middleware.ParseID(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant_id: %w", err)
+	}
+	jid, err := middleware.ParseID(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job_id: %w", err)
+	}
+	job, err := s.pg.GetJob(ctx, tid, jid)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil, errors.New("analysis job not found")
+		}
+		slog.Error("failed to retrieve job", "job_id", jobID, "error", err)
+		return nil, errors.New("failed to retrieve analysis job")
+	}
+	if job.Status != domain.JobStatusComplete {
+		return nil, errors.New("analysis is not yet complete")
+	}
+
+	sections := make([]streaming.DashboardSection, len(s.sections))
+	for i, build := range s.sections {
+		sections[i] = build(tenantID, job)
+	}
+	return sections, nil
+}
+
+// streamSection names a section load, logging its failure and reporting
+// it to the client as the REST handler would.
+func streamSection(name string, load func(ctx context.Context) (any, bool, error)) streaming.DashboardSection {
+	return streaming.DashboardSection{Name: name, Load: func(ctx context.Context) (any, bool, error) {
+		data, cached, err := load(ctx)
+		if err != nil {
+			slog.Warn("dashboard stream: section not available", "section", name, "error", err)
+			return nil, false, fmt.Errorf("%s data not available", name)
+		}
+		return data, cached, nil
+	}}
+}
+
+// streamSection is the handler's section of job for a streamed dashboard.
+func (h *sectionHandler[T]) streamSection(tenantID string, job *domain.AnalysisJob) streaming.DashboardSection {
+	return streamSection(h.section.name, func(ctx context.Context) (any, bool, error) {
+		data, cached, err := h.section.loadCached(ctx, h.redis, tenantID, job.ID.String())
+		if err != nil {
+			return nil, false, err
+		}
+		if h.decorate != nil {
+			h.decorate(ctx, job, data)
+		}
+		return data, cached, nil
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestDashboardStream_DashboardSections(t *testing.T) {
+	tenantID, jobID := uuid.New(), uuid.New()
+	baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID, jobID)
+	completeJob := &domain.AnalysisJob{ID: jobID, TenantID: tenantID, Status: domain.JobStatusComplete}
+
+	t.Run("loads every section in order", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		ch := new(testutil.MockClickHouseStore)
+		redis := new(testutil.MockRedisCache)
+		pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+		ch.On("GetQueueLoad", mock.Anything, tenantID.String(), jobID.String()).Return(nil, errors.New("unavailable"))
+		redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+		dashboardJSON, _ := json.Marshal(domain.DashboardData{GeneralStats: domain.GeneralStatistics{TotalLines: 42}})
+		redis.On("Get", mock.Anything, baseKey).Return(string(dashboardJSON), nil)
+		aggJSON, _ := json.Marshal(domain.AggregatesResponse{})
+		redis.On("Get", mock.Anything, baseKey+":agg").Return(string(aggJSON), nil)
+		redis.On("Get", mock.Anything, mock.Anything).Return("", errors.New("cache miss"))
+		redis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		sections, err := NewDashboardStream(pg, ch, redis).DashboardSections(context.Background(), tenantID.String(), jobID.String())
+		require.NoError(t, err)
+
+		var names []string
+		cached := map[string]bool{}
+		for _, s := range sections {
+			names = append(names, s.Name)
+			data, fromCache, err := s.Load(context.Background())
+			require.NoError(t, err, s.Name)
+			require.NotNil(t, data, s.Name)
+			cached[s.Name] = fromCache
+		}
+		assert.Equal(t, []string{"dashboard", "aggregates", "exceptions", "threads", "gaps", "filters",
+			"queued-calls", "logging-activity", "file-metadata"}, names)
+		assert.True(t, cached["dashboard"])
+		assert.True(t, cached["aggregates"])
+		assert.False(t, cached["exceptions"], "computed from the dashboard")
+		assert.False(t, cached["queued-calls"])
+	})
+
+	t.Run("missing dashboard fails its sections only", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		redis := new(testutil.MockRedisCache)
+		pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+		redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+		redis.On("Get", mock.Anything, mock.Anything).Return("", errors.New("cache miss"))
+
+		sections, err := NewDashboardStream(pg, new(testutil.MockClickHouseStore), redis).DashboardSections(context.Background(), tenantID.String(), jobID.String())
+		require.NoError(t, err)
+
+		_, _, err = sections[0].Load(context.Background())
+		assert.EqualError(t, err, "dashboard data not available")
+		_, _, err = sections[1].Load(context.Background())
+		assert.EqualError(t, err, "aggregates data not available")
+		_, _, err = sections[len(sections)-1].Load(context.Background())
+		assert.NoError(t, err, "file metadata stands in empty")
+	})
+
+	t.Run("job not complete", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, tenantID, jobID).Return(&domain.AnalysisJob{ID: jobID, Status: domain.JobStatusParsing}, nil)
+
+		_, err := NewDashboardStream(pg, nil, nil).DashboardSections(context.Background(), tenantID.String(), jobID.String())
+		assert.EqualError(t, err, "analysis is not yet complete")
+	})
+
+	t.Run("job of another tenant", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, tenantID, jobID).Return(nil, fmt.Errorf("postgres: job not found"))
+
+		_, err := NewDashboardStream(pg, nil, nil).DashboardSections(context.Background(), tenantID.String(), jobID.String())
+		assert.EqualError(t, err, "analysis job not found")
+	})
+}
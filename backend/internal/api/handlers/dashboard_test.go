@@ -58,15 +58,15 @@ func sampleDashboardData() *domain.DashboardData {
 			UniqueUsers:  25,
 			UniqueForms:  10,
 			UniqueTables: 8,
-			LogStart:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
-			LogEnd:       time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+			LogStart:     domain.NewTimestamp(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+			LogEnd:       domain.NewTimestamp(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)),
 			LogDuration:  "12h0m0s",
 		},
 		TopAPICalls:    []domain.TopNEntry{{Rank: 1, Identifier: "SYS:GetListEntry", DurationMS: 420}},
 		TopSQL:         []domain.TopNEntry{{Rank: 1, Identifier: "SELECT FROM arschema", DurationMS: 310}},
 		TopFilters:     []domain.TopNEntry{{Rank: 1, Identifier: "HPD:HelpDesk", DurationMS: 150}},
 		TopEscalations: []domain.TopNEntry{{Rank: 1, Identifier: "HPD:ResolveEsc", DurationMS: 90}},
-		TimeSeries:     []domain.TimeSeriesPoint{{Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), APICount: 200}},
+		TimeSeries:     []domain.TimeSeriesPoint{{Timestamp: domain.NewTimestamp(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)), APICount: 200}},
 		Distribution:   map[string]map[string]int{"api": {"success": 4800, "error": 200}},
 	}
 }
@@ -338,6 +338,105 @@ func TestDashboardHandler_CacheHit(t *testing.T) {
 	redis.AssertExpectations(t)
 }
 
+func TestDashboardHandler_IncludesFirstErrorTimestamp(t *testing.T) {
+	pg, _, redis := newDashboardMocks()
+	handler := NewDashboardHandler(pg, nil, redis)
+
+	tenantID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	jobID := uuid.New()
+	cacheKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+	firstErr := time.Date(2026, 1, 1, 3, 15, 0, 0, time.UTC)
+
+	job := completedJob(tenantID, jobID)
+	job.FirstErrorAt = &firstErr
+	pg.On("GetJob", mock.Anything, tenantID, jobID).Return(job, nil)
+	redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(cacheKey)
+	cachedJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+	redis.On("Get", mock.Anything, cacheKey).Return(string(cachedJSON), nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, makeDashboardRequest(tenantID.String(), jobID.String()))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respData domain.DashboardData
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respData))
+	require.NotNil(t, respData.FirstErrorAt)
+	assert.True(t, respData.FirstErrorAt.Equal(firstErr))
+}
+
+func TestDashboardHandler_IncludesDataQuality(t *testing.T) {
+	pg, _, redis := newDashboardMocks()
+	handler := NewDashboardHandler(pg, nil, redis)
+
+	tenantID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	jobID := uuid.New()
+	cacheKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+	quality := domain.DataQualityMajorDivergence
+
+	job := completedJob(tenantID, jobID)
+	job.DataQuality = &quality
+	job.Reconciliation = &domain.IngestionReconciliation{
+		Quality:  quality,
+		Counts:   []domain.CountReconciliation{{LogType: "total", JARCount: 200, Expected: 200, Stored: 150, DivergencePct: 25}},
+		MinorPct: 1,
+		MajorPct: 5,
+	}
+	pg.On("GetJob", mock.Anything, tenantID, jobID).Return(job, nil)
+	redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(cacheKey)
+	cachedJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+	redis.On("Get", mock.Anything, cacheKey).Return(string(cachedJSON), nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, makeDashboardRequest(tenantID.String(), jobID.String()))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respData domain.DashboardData
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respData))
+	require.NotNil(t, respData.DataQuality)
+	assert.Equal(t, domain.DataQualityMajorDivergence, *respData.DataQuality)
+	require.NotNil(t, respData.Reconciliation)
+	assert.Equal(t, int64(150), respData.Reconciliation.Counts[0].Stored)
+}
+
+func TestDashboardHandler_IncludesFocusWindow(t *testing.T) {
+	pg, _, redis := newDashboardMocks()
+	handler := NewDashboardHandler(pg, nil, redis)
+
+	tenantID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	jobID := uuid.New()
+	cacheKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	job := completedJob(tenantID, jobID)
+	job.FocusWindow = &domain.FocusWindow{
+		Start:     domain.NewTimestamp(start.Add(10 * time.Hour)),
+		End:       domain.NewTimestamp(start.Add(10*time.Hour + 30*time.Minute)),
+		FullStart: domain.NewTimestamp(start),
+		FullEnd:   domain.NewTimestamp(start.Add(24 * time.Hour)),
+		BucketMS:  900000,
+		Score:     4.2,
+		Signals:   []string{domain.FocusSignalErrorRate},
+	}
+	pg.On("GetJob", mock.Anything, tenantID, jobID).Return(job, nil)
+	redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(cacheKey)
+	cachedJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+	redis.On("Get", mock.Anything, cacheKey).Return(string(cachedJSON), nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, makeDashboardRequest(tenantID.String(), jobID.String()))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respData domain.DashboardData
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respData))
+	require.NotNil(t, respData.FocusWindow)
+	assert.True(t, respData.FocusWindow.Start.Equal(start.Add(10*time.Hour)))
+	assert.True(t, respData.FocusWindow.FullEnd.Equal(start.Add(24*time.Hour)))
+	assert.Equal(t, []string{domain.FocusSignalErrorRate}, respData.FocusWindow.Signals)
+}
+
 func TestDashboardHandler_CacheMiss_Returns500(t *testing.T) {
 	pg, _, redis := newDashboardMocks()
 	handler := NewDashboardHandler(pg, nil, redis)
@@ -588,3 +687,36 @@ func TestDashboardHandler_WithTestUtilHelpers(t *testing.T) {
 	pg.AssertExpectations(t)
 	redis.AssertExpectations(t)
 }
+
+func TestDashboardHandler_RestartMarkers(t *testing.T) {
+	pg, _, redis := newDashboardMocks()
+	handler := NewDashboardHandler(pg, nil, redis)
+
+	tenantID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	jobID := uuid.New()
+	cacheKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+
+	restartAt := domain.NewTimestamp(time.Date(2025, 11, 24, 14, 46, 0, 0, time.UTC))
+	warmupEnd := domain.NewTimestamp(restartAt.Add(5 * time.Minute))
+	job := completedJob(tenantID, jobID)
+	job.Restarts = []domain.RestartEvent{{Timestamp: restartAt, WarmupEnd: &warmupEnd, Confidence: 0.95}}
+	pg.On("GetJob", mock.Anything, tenantID, jobID).Return(job, nil)
+	redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(cacheKey)
+	cachedJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+	redis.On("Get", mock.Anything, cacheKey).Return(string(cachedJSON), nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, makeDashboardRequest(tenantID.String(), jobID.String()))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respData domain.DashboardData
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respData))
+	require.Len(t, respData.Markers, 1)
+	m := respData.Markers[0]
+	assert.Equal(t, domain.TimeSeriesMarkerRestart, m.Kind)
+	assert.True(t, restartAt.Equal(m.Timestamp.Time))
+	require.NotNil(t, m.EndTime)
+	assert.True(t, warmupEnd.Equal(m.EndTime.Time))
+	assert.Equal(t, 0.95, m.Confidence)
+}
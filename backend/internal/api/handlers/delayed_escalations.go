@@ -1,12 +1,10 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
@@ -33,16 +31,13 @@ func (h *DelayedEscalationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
 
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+	tid, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -72,7 +67,12 @@ func (h *DelayedEscalationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 	}
 
 	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+		sectionNotComplete(w)
+		return
+	}
+
+	etag := sectionETag(job, fmt.Sprintf("delayed-escalations:%d:%d", minDelayMS, limit))
+	if sectionNotModified(w, r, etag) {
 		return
 	}
 
@@ -107,5 +107,5 @@ func (h *DelayedEscalationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		resp.Entries = []domain.DelayedEscalationEntry{}
 	}
 
-	api.JSON(w, http.StatusOK, resp)
+	writeSection(w, etag, resp, true)
 }
@@ -45,8 +45,8 @@ func TestDelayedEscalationsHandler_ServeHTTP(t *testing.T) {
 		{
 			EscName:       "ESC:OverdueIncident",
 			EscPool:       "Admin",
-			ScheduledTime: &scheduledTime,
-			ActualTime:    now,
+			ScheduledTime: domain.TimestampPtr(&scheduledTime),
+			ActualTime:    domain.NewTimestamp(now),
 			DelayMS:       5000,
 			ThreadID:      "T001",
 			TraceID:       "trace-esc-001",
@@ -55,8 +55,8 @@ func TestDelayedEscalationsHandler_ServeHTTP(t *testing.T) {
 		{
 			EscName:       "ESC:SLABreach",
 			EscPool:       "Support",
-			ScheduledTime: &scheduledTime,
-			ActualTime:    now,
+			ScheduledTime: domain.TimestampPtr(&scheduledTime),
+			ActualTime:    domain.NewTimestamp(now),
 			DelayMS:       3000,
 			ThreadID:      "T002",
 			TraceID:       "trace-esc-002",
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/diagnostics"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+)
+
+// DiagnosticsHandler serves GET /api/v1/admin/analyses/{job_id}/diagnostics:
+// the diagnostic bundle of an analysis as a JSON download, for support to
+// investigate a failed job in one round trip. Sections whose source is
+// down read "unavailable: <reason>" rather than failing the download.
+//
+// Every download is recorded in the job's event log and in the server log,
+// with the admin who made it.
+type DiagnosticsHandler struct {
+	assembler *diagnostics.Assembler
+	events    *jobevents.Appender
+}
+
+// NewDiagnosticsHandler creates the handler.
+func NewDiagnosticsHandler(assembler *diagnostics.Assembler, events *jobevents.Appender) *DiagnosticsHandler {
+	return &DiagnosticsHandler{assembler: assembler, events: events}
+}
+
+func (h *DiagnosticsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	bundle, err := h.assembler.Assemble(r.Context(), tid, jobID)
+	if err != nil {
+		if errors.Is(err, diagnostics.ErrJobNotFound) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to assemble diagnostics")
+		}
+		return
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		slog.Error("diagnostics: encode bundle", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to assemble diagnostics")
+		return
+	}
+
+	actor := middleware.GetUserID(r.Context())
+	slog.Info("diagnostic bundle downloaded", "tenant_id", tenantID, "job_id", jobID, "user_id", actor,
+		"bytes", len(data), "omitted", bundle.Omitted)
+	h.events.Append(domain.JobEvent{TenantID: tid, JobID: jobID, Kind: domain.JobEventDiagnostics,
+		Message: "diagnostic bundle downloaded", Metadata: map[string]any{"user_id": actor, "bytes": len(data)}})
+
+	noStore(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"diagnostics-%s.json\"", jobID))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(data, '\n'))
+}
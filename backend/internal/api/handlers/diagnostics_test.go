@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/diagnostics"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	path := "/api/v1/admin/analyses/" + fixedJobID.String() + "/diagnostics"
+
+	t.Run("bundle with unavailable sections", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		m.pg.On("ListJobEvents", mock.Anything, fixedTenantID, fixedJobID).Return(nil, fmt.Errorf("postgres: list job events: timeout"))
+		var events []domain.JobEvent
+		m.pg.On("AppendJobEvents", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { events = append(events, args.Get(1).([]domain.JobEvent)...) }).
+			Return(0, nil)
+		appender := jobevents.NewAppender(m.pg, domain.JobEventSourceAPI, 0, 0, time.Hour)
+		assembler := diagnostics.NewAssembler(m.pg, nil, nil, nil, nil, nil, diagnostics.Options{})
+
+		rr := newTestRequest(http.MethodGet, path).
+			tenant(fixedTenantID.String()).user("admin-1").vars("job_id", fixedJobID.String()).
+			serve(NewDiagnosticsHandler(assembler, appender))
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Header().Get("Content-Disposition"), "diagnostics-"+fixedJobID.String()+".json")
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+		var bundle map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bundle))
+		assert.JSONEq(t, `"unavailable: postgres: list job events: timeout"`, string(bundle["events"]))
+		assert.JSONEq(t, `"unavailable: no Redis client"`, string(bundle["cache"]))
+		assert.Contains(t, string(bundle["job"]), `"record"`)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		appender.Run(ctx)
+		require.Len(t, events, 1)
+		assert.Equal(t, domain.JobEventDiagnostics, events[0].Kind)
+		assert.Equal(t, "admin-1", events[0].Metadata["user_id"])
+		m.assertExpectations(t)
+	})
+
+	t.Run("job not found", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, fmt.Errorf("postgres: get job: not found"))
+		assembler := diagnostics.NewAssembler(m.pg, nil, nil, nil, nil, nil, diagnostics.Options{})
+
+		rr := newTestRequest(http.MethodGet, path).
+			tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).
+			serve(NewDiagnosticsHandler(assembler, nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, api.ErrCodeNotFound, decodeError(t, rr).Code)
+	})
+
+	t.Run("invalid job id", func(t *testing.T) {
+		rr := newTestRequest(http.MethodGet, "/api/v1/admin/analyses/nope/diagnostics").
+			tenant(fixedTenantID.String()).vars("job_id", "nope").
+			serve(NewDiagnosticsHandler(nil, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("missing tenant", func(t *testing.T) {
+		rr := newTestRequest(http.MethodGet, path).vars("job_id", fixedJobID.String()).
+			serve(NewDiagnosticsHandler(nil, nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// maxDigestRecipients caps the recipient list of a digest subscription.
+const maxDigestRecipients = 20
+
+// DigestSubscriptionHandler serves GET and PUT /api/v1/digest/subscription,
+// the tenant's opt-in to the daily analysis digest.
+type DigestSubscriptionHandler struct {
+	pg storage.PostgresStore
+}
+
+func NewDigestSubscriptionHandler(pg storage.PostgresStore) *DigestSubscriptionHandler {
+	return &DigestSubscriptionHandler{pg: pg}
+}
+
+type digestSubscriptionRequest struct {
+	Enabled    *bool    `json:"enabled"`
+	Recipients []string `json:"recipients"`
+	SendHour   int      `json:"send_hour"`
+	Timezone   string   `json:"timezone"`
+}
+
+func (h *DigestSubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r, tenantUUID)
+	case http.MethodPut:
+		h.put(w, r, tenantUUID)
+	default:
+		api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+	}
+}
+
+func (h *DigestSubscriptionHandler) get(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID) {
+	sub, err := h.pg.GetDigestSubscription(r.Context(), tenantID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			// Tenants that never opted in see the disabled defaults.
+			api.JSON(w, http.StatusOK, domain.DigestSubscription{
+				TenantID:   tenantID,
+				Recipients: []string{},
+				SendHour:   7,
+				Timezone:   "UTC",
+			})
+			return
+		}
+		slog.Error("get digest subscription failed", "tenant_id", tenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve digest subscription")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, sub)
+}
+
+func (h *DigestSubscriptionHandler) put(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID) {
+	var req digestSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+
+	sub := &domain.DigestSubscription{
+		TenantID:   tenantID,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		Recipients: req.Recipients,
+		SendHour:   req.SendHour,
+		Timezone:   req.Timezone,
+	}
+	if err := validateDigestSubscription(sub); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	if err := h.pg.UpsertDigestSubscription(r.Context(), sub); err != nil {
+		slog.Error("upsert digest subscription failed", "tenant_id", tenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to save digest subscription")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, sub)
+}
+
+// validateDigestSubscription checks the recipients, send hour and timezone,
+// normalising recipients to bare addresses and defaulting the timezone to UTC.
+func validateDigestSubscription(sub *domain.DigestSubscription) error {
+	if sub.Enabled && len(sub.Recipients) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+	if len(sub.Recipients) > maxDigestRecipients {
+		return fmt.Errorf("at most %d recipients are allowed", maxDigestRecipients)
+	}
+	recipients := make([]string, 0, len(sub.Recipients))
+	for _, rcpt := range sub.Recipients {
+		addr, err := mail.ParseAddress(rcpt)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q", rcpt)
+		}
+		recipients = append(recipients, addr.Address)
+	}
+	sub.Recipients = recipients
+
+	if sub.SendHour < 0 || sub.SendHour > 23 {
+		return fmt.Errorf("send_hour must be between 0 and 23")
+	}
+	if sub.Timezone == "" {
+		sub.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(sub.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", sub.Timezone)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestDigestSubscriptionHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		tenantID   string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "get returns stored subscription",
+			method:   http.MethodGet,
+			tenantID: fixedTenantID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetDigestSubscription", mock.Anything, fixedTenantID).Return(&domain.DigestSubscription{
+					TenantID: fixedTenantID, Enabled: true, Recipients: []string{"ops@example.com"}, SendHour: 6, Timezone: "Europe/Berlin",
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.DigestSubscription
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.True(t, resp.Enabled)
+				assert.Equal(t, "Europe/Berlin", resp.Timezone)
+			},
+		},
+		{
+			name:     "get without subscription returns disabled defaults",
+			method:   http.MethodGet,
+			tenantID: fixedTenantID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetDigestSubscription", mock.Anything, fixedTenantID).
+					Return(nil, fmt.Errorf("postgres: digest subscription not found: %s", fixedTenantID))
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.DigestSubscription
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.False(t, resp.Enabled)
+				assert.Equal(t, "UTC", resp.Timezone)
+			},
+		},
+		{
+			name:     "put opts in with normalised recipients",
+			method:   http.MethodPut,
+			tenantID: fixedTenantID.String(),
+			body:     `{"recipients":["Ops Team <ops@example.com>"],"send_hour":7,"timezone":"America/New_York"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("UpsertDigestSubscription", mock.Anything, mock.MatchedBy(func(s *domain.DigestSubscription) bool {
+					return s.TenantID == fixedTenantID && s.Enabled && s.SendHour == 7 &&
+						len(s.Recipients) == 1 && s.Recipients[0] == "ops@example.com"
+				})).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:     "put may disable without recipients",
+			method:   http.MethodPut,
+			tenantID: fixedTenantID.String(),
+			body:     `{"enabled":false}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("UpsertDigestSubscription", mock.Anything, mock.MatchedBy(func(s *domain.DigestSubscription) bool {
+					return !s.Enabled && s.Timezone == "UTC"
+				})).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "put without recipients returns 400",
+			method:     http.MethodPut,
+			tenantID:   fixedTenantID.String(),
+			body:       `{"send_hour":7}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid recipient returns 400",
+			method:     http.MethodPut,
+			tenantID:   fixedTenantID.String(),
+			body:       `{"recipients":["not an address"]}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid send hour returns 400",
+			method:     http.MethodPut,
+			tenantID:   fixedTenantID.String(),
+			body:       `{"recipients":["ops@example.com"],"send_hour":24}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown timezone returns 400",
+			method:     http.MethodPut,
+			tenantID:   fixedTenantID.String(),
+			body:       `{"recipients":["ops@example.com"],"timezone":"Mars/Olympus"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "store error returns 500",
+			method:   http.MethodPut,
+			tenantID: fixedTenantID.String(),
+			body:     `{"recipients":["ops@example.com"]}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("UpsertDigestSubscription", mock.Anything, mock.Anything).Return(errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "missing tenant returns 401",
+			method:     http.MethodGet,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			req := httptest.NewRequest(tc.method, "/api/v1/digest/subscription", bytes.NewBufferString(tc.body))
+			if tc.tenantID != "" {
+				req = injectAuth(req, tc.tenantID)
+			}
+
+			w := httptest.NewRecorder()
+			NewDigestSubscriptionHandler(pg).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
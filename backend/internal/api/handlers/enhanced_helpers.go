@@ -8,188 +8,98 @@ import (
 	"time"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
 )
 
 const sectionCacheTTL = 24 * time.Hour
 
-// isJARParsedCache checks if a cached JSON string contains the jar_parsed source marker.
-func isJARParsedCache(cached string) bool {
-	return strings.Contains(cached, `"source":"jar_parsed"`)
-}
-
-func getOrComputeAggregates(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, error) {
-	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":agg"
-	cached, err := redis.Get(ctx, cacheKey)
-	if err == nil && cached != "" {
-		// Try JAR-native type first.
-		if isJARParsedCache(cached) {
-			var jarData domain.JARAggregatesResponse
-			if err := json.Unmarshal([]byte(cached), &jarData); err == nil {
-				return &jarData, nil
-			}
-		}
-		// Fall back to computed type.
-		var data domain.AggregatesResponse
-		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
-		}
+// jarFiltersSection decodes the JAR filter sections, recovering the full
+// names of those it truncated: sections cached before the names were
+// resolved still carry the JAR's truncation markers.
+func jarFiltersSection(cached string) (any, bool) {
+	data, ok := jarSection[domain.JARFilterComplexityResponse](cached)
+	if ok {
+		jar.ResolveTruncatedNames(data.(*domain.JARFilterComplexityResponse), nil, nil)
 	}
-
-	dashboard, err := getDashboardFromCache(ctx, redis, tenantID, jobID)
-	if err != nil {
-		return nil, err
-	}
-
-	result := worker.ComputeEnhancedSections(dashboard)
-	if result.Aggregates == nil {
-		return &domain.AggregatesResponse{}, nil
-	}
-
-	_ = redis.Set(ctx, cacheKey, result.Aggregates, sectionCacheTTL)
-	return result.Aggregates, nil
+	return data, ok
 }
 
-func getOrComputeExceptions(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, error) {
-	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":exc"
-	cached, err := redis.Get(ctx, cacheKey)
-	if err == nil && cached != "" {
-		if isJARParsedCache(cached) {
-			var jarData domain.JARExceptionsResponse
-			if err := json.Unmarshal([]byte(cached), &jarData); err == nil {
-				return &jarData, nil
-			}
-		}
-		var data domain.ExceptionsResponse
-		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
-		}
-	}
-
-	dashboard, err := getDashboardFromCache(ctx, redis, tenantID, jobID)
-	if err != nil {
-		return nil, err
-	}
-
-	result := worker.ComputeEnhancedSections(dashboard)
-	if result.Exceptions == nil {
-		return &domain.ExceptionsResponse{
-			Exceptions: []domain.ExceptionEntry{},
-			ErrorRates: make(map[string]float64),
-			TopCodes:   []string{},
-		}, nil
-	}
-
-	_ = redis.Set(ctx, cacheKey, result.Exceptions, sectionCacheTTL)
-	return result.Exceptions, nil
+// isJARParsedCache checks if a cached JSON string contains the jar_parsed source marker.
+func isJARParsedCache(cached string) bool {
+	return strings.Contains(cached, `"source":"jar_parsed"`)
 }
 
-func getOrComputeGaps(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, error) {
-	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":gaps"
-	cached, err := redis.Get(ctx, cacheKey)
-	if err == nil && cached != "" {
-		if isJARParsedCache(cached) {
-			var jarData domain.JARGapsResponse
-			if err := json.Unmarshal([]byte(cached), &jarData); err == nil {
-				return &jarData, nil
+// The sections computed from the dashboard when the JAR did not provide
+// them. Each backs a section handler and a part of the report.
+var (
+	aggregatesSection = section[domain.AggregatesResponse]{
+		name:      "aggregates",
+		cacheKey:  "agg",
+		decodeJAR: jarSection[domain.JARAggregatesResponse],
+		compute:   func(r *domain.ParseResult) *domain.AggregatesResponse { return r.Aggregates },
+	}
+
+	exceptionsSection = section[domain.ExceptionsResponse]{
+		name:      "exceptions",
+		cacheKey:  "exc",
+		decodeJAR: jarSection[domain.JARExceptionsResponse],
+		compute:   func(r *domain.ParseResult) *domain.ExceptionsResponse { return r.Exceptions },
+		empty: func() *domain.ExceptionsResponse {
+			return &domain.ExceptionsResponse{
+				Exceptions: []domain.ExceptionEntry{},
+				ErrorRates: make(map[string]float64),
+				TopCodes:   []string{},
 			}
-		}
-		var data domain.GapsResponse
-		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
-		}
-	}
-
-	dashboard, err := getDashboardFromCache(ctx, redis, tenantID, jobID)
-	if err != nil {
-		return nil, err
-	}
-
-	result := worker.ComputeEnhancedSections(dashboard)
-	if result.Gaps == nil {
-		return &domain.GapsResponse{
-			Gaps:        []domain.GapEntry{},
-			QueueHealth: []domain.QueueHealthSummary{},
-		}, nil
-	}
-
-	_ = redis.Set(ctx, cacheKey, result.Gaps, sectionCacheTTL)
-	return result.Gaps, nil
-}
-
-func getOrComputeThreads(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, error) {
-	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":threads"
-	cached, err := redis.Get(ctx, cacheKey)
-	if err == nil && cached != "" {
-		if isJARParsedCache(cached) {
-			var jarData domain.JARThreadStatsResponse
-			if err := json.Unmarshal([]byte(cached), &jarData); err == nil {
-				return &jarData, nil
+		},
+	}
+
+	gapsSection = section[domain.GapsResponse]{
+		name:      "gaps",
+		cacheKey:  "gaps",
+		decodeJAR: jarSection[domain.JARGapsResponse],
+		compute:   func(r *domain.ParseResult) *domain.GapsResponse { return r.Gaps },
+		empty: func() *domain.GapsResponse {
+			return &domain.GapsResponse{
+				Gaps:        []domain.GapEntry{},
+				QueueHealth: []domain.QueueHealthSummary{},
 			}
-		}
-		var data domain.ThreadStatsResponse
-		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
-		}
-	}
-
-	dashboard, err := getDashboardFromCache(ctx, redis, tenantID, jobID)
-	if err != nil {
-		return nil, err
-	}
-
-	result := worker.ComputeEnhancedSections(dashboard)
-	if result.ThreadStats == nil {
-		return &domain.ThreadStatsResponse{
-			Threads: []domain.ThreadStatsEntry{},
-		}, nil
-	}
-
-	_ = redis.Set(ctx, cacheKey, result.ThreadStats, sectionCacheTTL)
-	return result.ThreadStats, nil
-}
-
-func getOrComputeFilters(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, error) {
-	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":filters"
-	cached, err := redis.Get(ctx, cacheKey)
-	if err == nil && cached != "" {
-		if isJARParsedCache(cached) {
-			var jarData domain.JARFilterComplexityResponse
-			if err := json.Unmarshal([]byte(cached), &jarData); err == nil {
-				return &jarData, nil
+		},
+	}
+
+	threadsSection = section[domain.ThreadStatsResponse]{
+		name:      "threads",
+		cacheKey:  "threads",
+		decodeJAR: jarSection[domain.JARThreadStatsResponse],
+		compute:   func(r *domain.ParseResult) *domain.ThreadStatsResponse { return r.ThreadStats },
+		empty: func() *domain.ThreadStatsResponse {
+			return &domain.ThreadStatsResponse{Threads: []domain.ThreadStatsEntry{}}
+		},
+	}
+
+	filtersSection = section[domain.FilterComplexityResponse]{
+		name:      "filters",
+		cacheKey:  "filters",
+		decodeJAR: jarFiltersSection,
+		compute:   func(r *domain.ParseResult) *domain.FilterComplexityResponse { return r.Filters },
+		empty: func() *domain.FilterComplexityResponse {
+			return &domain.FilterComplexityResponse{
+				MostExecuted:   []domain.MostExecutedFilter{},
+				PerTransaction: []domain.FilterPerTransaction{},
 			}
-		}
-		var data domain.FilterComplexityResponse
-		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
-		}
+		},
 	}
+)
 
-	dashboard, err := getDashboardFromCache(ctx, redis, tenantID, jobID)
-	if err != nil {
-		return nil, err
-	}
-
-	result := worker.ComputeEnhancedSections(dashboard)
-	if result.Filters == nil {
-		return &domain.FilterComplexityResponse{
-			MostExecuted:   []domain.MostExecutedFilter{},
-			PerTransaction: []domain.FilterPerTransaction{},
-		}, nil
-	}
-
-	_ = redis.Set(ctx, cacheKey, result.Filters, sectionCacheTTL)
-	return result.Filters, nil
-}
-
-func getOrComputeQueuedCalls(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.QueuedCallsResponse, error) {
+// getOrComputeQueuedCalls returns the cached queued calls and whether they were found;
+// otherwise an empty response stands in for them.
+func getOrComputeQueuedCalls(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.QueuedCallsResponse, bool, error) {
 	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":queued"
 	cached, err := redis.Get(ctx, cacheKey)
 	if err == nil && cached != "" {
 		var data domain.QueuedCallsResponse
 		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
+			return &data, true, nil
 		}
 	}
 
@@ -198,16 +108,18 @@ func getOrComputeQueuedCalls(ctx context.Context, redis storage.RedisCache, tena
 		JobID:          jobID,
 		QueuedAPICalls: []domain.TopNEntry{},
 		Total:          0,
-	}, nil
+	}, false, nil
 }
 
-func getOrComputeLoggingActivity(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.LoggingActivityResponse, error) {
+// getOrComputeLoggingActivity returns the cached logging activity and whether they were found;
+// otherwise an empty response stands in for them.
+func getOrComputeLoggingActivity(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.LoggingActivityResponse, bool, error) {
 	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":logging-activity"
 	cached, err := redis.Get(ctx, cacheKey)
 	if err == nil && cached != "" {
 		var data domain.LoggingActivityResponse
 		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
+			return &data, true, nil
 		}
 	}
 
@@ -215,16 +127,18 @@ func getOrComputeLoggingActivity(ctx context.Context, redis storage.RedisCache,
 	return &domain.LoggingActivityResponse{
 		JobID:      jobID,
 		Activities: []domain.LoggingActivity{},
-	}, nil
+	}, false, nil
 }
 
-func getOrComputeFileMetadata(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.FileMetadataResponse, error) {
+// getOrComputeFileMetadata returns the cached file metadata and whether it was found;
+// otherwise an empty response stands in for them.
+func getOrComputeFileMetadata(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.FileMetadataResponse, bool, error) {
 	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":file-metadata"
 	cached, err := redis.Get(ctx, cacheKey)
 	if err == nil && cached != "" {
 		var data domain.FileMetadataResponse
 		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return &data, nil
+			return &data, true, nil
 		}
 	}
 
@@ -233,7 +147,7 @@ func getOrComputeFileMetadata(ctx context.Context, redis storage.RedisCache, ten
 		JobID: jobID,
 		Files: []domain.FileMetadata{},
 		Total: 0,
-	}, nil
+	}, false, nil
 }
 
 func getDashboardFromCache(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (*domain.DashboardData, error) {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/explain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
@@ -44,6 +45,61 @@ func (h *EntryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	api.JSON(w, http.StatusOK, entry)
 }
 
+// EntryResolveHandler maps a file/line position to its entry ID so external
+// tools and the raw-log viewer can deep-link to an entry.
+type EntryResolveHandler struct {
+	ch storage.ClickHouseStore
+}
+
+func NewEntryResolveHandler(ch storage.ClickHouseStore) *EntryResolveHandler {
+	return &EntryResolveHandler{ch: ch}
+}
+
+// ServeHTTP handles GET /api/v1/analysis/{job_id}/entries/resolve?file=N&line=M.
+// file defaults to 1.
+func (h *EntryResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	jobID := mux.Vars(r)["job_id"]
+	if jobID == "" {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "job_id is required")
+		return
+	}
+
+	fileNumber := uint64(1)
+	if fileStr := r.URL.Query().Get("file"); fileStr != "" {
+		parsed, err := strconv.ParseUint(fileStr, 10, 16)
+		if err != nil || parsed == 0 {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "file must be a positive integer")
+			return
+		}
+		fileNumber = parsed
+	}
+
+	lineNumber, err := strconv.ParseUint(r.URL.Query().Get("line"), 10, 32)
+	if err != nil || lineNumber == 0 {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "line must be a positive integer")
+		return
+	}
+
+	entryID, err := h.ch.ResolveEntryID(r.Context(), tenantID, jobID, uint16(fileNumber), uint32(lineNumber))
+	if err != nil {
+		api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "no entry at the given file and line")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, map[string]interface{}{
+		"job_id":      jobID,
+		"entry_id":    entryID,
+		"file_number": fileNumber,
+		"line_number": lineNumber,
+	})
+}
+
 type ContextHandler struct {
 	ch storage.ClickHouseStore
 }
@@ -83,3 +139,56 @@ func (h *ContextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	api.JSON(w, http.StatusOK, ctx)
 }
+
+// EntryExplainHandler serves GET
+// /api/v1/analysis/{job_id}/entries/{entry_id}/explain, the entry joined
+// with its API name, AR error, trace position and duration percentile.
+type EntryExplainHandler struct {
+	pg        storage.PostgresStore
+	explainer *explain.Explainer
+}
+
+func NewEntryExplainHandler(pg storage.PostgresStore, ch storage.ClickHouseStore) *EntryExplainHandler {
+	return &EntryExplainHandler{pg: pg, explainer: explain.NewExplainer(ch, pg, ch, ch)}
+}
+
+func (h *EntryExplainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+	entryID := vars["entry_id"]
+	if entryID == "" {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "entry_id is required")
+		return
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.pg.GetJob(r.Context(), tid, jobID); err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	explanation, err := h.explainer.Explain(r.Context(), tid, jobID, entryID)
+	if err != nil {
+		api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "entry not found")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, explanation)
+}
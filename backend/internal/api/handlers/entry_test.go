@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestEntryResolveHandler(t *testing.T) {
+	const entryID = "0b6f6a3e-6f1d-5c2a-9d5e-2f0c1b7a4e99"
+
+	tests := []struct {
+		name       string
+		tenantID   string
+		query      string
+		setupMocks func(ch *testutil.MockClickHouseStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "resolves file and line",
+			tenantID: fixedTenantID.String(),
+			query:    "?file=2&line=1500",
+			setupMocks: func(ch *testutil.MockClickHouseStore) {
+				ch.On("ResolveEntryID", mock.Anything, fixedTenantID.String(), fixedJobID.String(), uint16(2), uint32(1500)).Return(entryID, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, entryID, resp["entry_id"])
+				assert.Equal(t, float64(2), resp["file_number"])
+				assert.Equal(t, float64(1500), resp["line_number"])
+			},
+		},
+		{
+			name:     "file defaults to 1",
+			tenantID: fixedTenantID.String(),
+			query:    "?line=7",
+			setupMocks: func(ch *testutil.MockClickHouseStore) {
+				ch.On("ResolveEntryID", mock.Anything, fixedTenantID.String(), fixedJobID.String(), uint16(1), uint32(7)).Return(entryID, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing line returns 400",
+			tenantID:   fixedTenantID.String(),
+			query:      "?file=1",
+			setupMocks: func(ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid file returns 400",
+			tenantID:   fixedTenantID.String(),
+			query:      "?file=0&line=7",
+			setupMocks: func(ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing tenant returns 401",
+			query:      "?line=7",
+			setupMocks: func(ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "unknown position returns 404",
+			tenantID: fixedTenantID.String(),
+			query:    "?line=999999",
+			setupMocks: func(ch *testutil.MockClickHouseStore) {
+				ch.On("ResolveEntryID", mock.Anything, fixedTenantID.String(), fixedJobID.String(), uint16(1), uint32(999999)).
+					Return("", errors.New("clickhouse: resolve entry: sql: no rows in result set"))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ch := new(testutil.MockClickHouseStore)
+			tc.setupMocks(ch)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/entries/resolve"+tc.query, nil)
+			if tc.tenantID != "" {
+				req = injectAuth(req, tc.tenantID)
+			}
+			req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String()})
+
+			w := httptest.NewRecorder()
+			NewEntryResolveHandler(ch).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			ch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEntryExplainHandler(t *testing.T) {
+	const entryID = "0b6f6a3e-6f1d-5c2a-9d5e-2f0c1b7a4e99"
+
+	tests := []struct {
+		name       string
+		jobID      string
+		setupMocks func(m *handlerMocks)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "explains the entry",
+			jobID: fixedJobID.String(),
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+				m.pg.On("GetJobAPILegend", mock.Anything, fixedTenantID, fixedJobID).Return(nil, nil)
+				m.ch.On("GetLogEntry", mock.Anything, fixedTenantID.String(), fixedJobID.String(), entryID).
+					Return(&domain.LogEntry{EntryID: entryID, LogType: domain.LogTypeAPI, APICode: "GE", ErrorMessage: "ARERR [302] Entry does not exist in database"}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.EntryExplanation
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, entryID, resp.Entry.EntryID)
+				require.NotNil(t, resp.API)
+				assert.Equal(t, "ARGetEntry", resp.API.Name)
+				require.NotNil(t, resp.Error)
+				assert.Equal(t, 302, resp.Error.Code)
+				assert.Nil(t, resp.Trace)
+				assert.Equal(t, "entry has no trace ID", resp.Unavailable["trace"])
+				assert.Contains(t, resp.Unavailable, "duration")
+			},
+		},
+		{
+			name:       "invalid job_id returns 400",
+			jobID:      "nope",
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "unknown job returns 404",
+			jobID: fixedJobID.String(),
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, errors.New("postgres: job not found"))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "unknown entry returns 404",
+			jobID: fixedJobID.String(),
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+				m.ch.On("GetLogEntry", mock.Anything, fixedTenantID.String(), fixedJobID.String(), entryID).Return(nil, errors.New("clickhouse: get entry: no rows"))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			tc.setupMocks(m)
+
+			w := newTestRequest(http.MethodGet, "/api/v1/analysis/"+tc.jobID+"/entries/"+entryID+"/explain").
+				tenant(fixedTenantID.String()).
+				vars("job_id", tc.jobID, "entry_id", entryID).
+				serve(NewEntryExplainHandler(m.pg, m.ch))
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			m.assertExpectations(t)
+		})
+	}
+}
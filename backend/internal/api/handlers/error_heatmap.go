@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	defaultHeatmapRows = 10
+	maxHeatmapRows     = 50
+)
+
+// ErrorHeatmapHandler serves GET /api/v1/analysis/{job_id}/dashboard/exceptions/heatmap.
+// It returns an error code × time bucket matrix so spikes of individual
+// errors can be located during an incident. Results are cached per bucket
+// size and row limit.
+type ErrorHeatmapHandler struct {
+	pg    storage.PostgresStore
+	ch    storage.ClickHouseStore
+	redis storage.RedisCache
+}
+
+// NewErrorHeatmapHandler creates a new handler for the exceptions heatmap endpoint.
+func NewErrorHeatmapHandler(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *ErrorHeatmapHandler {
+	return &ErrorHeatmapHandler{pg: pg, ch: ch, redis: redis}
+}
+
+func (h *ErrorHeatmapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = "auto"
+	}
+	if !storage.IsValidHeatmapBucket(bucket) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "bucket must be one of auto, 1m, 5m")
+		return
+	}
+	limit := defaultHeatmapRows
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= maxHeatmapRows {
+			limit = parsed
+		}
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	if job.Status != domain.JobStatusComplete {
+		sectionNotComplete(w)
+		return
+	}
+
+	etag := sectionETag(job, fmt.Sprintf("exc-heatmap:%s:%d", bucket, limit))
+	if sectionNotModified(w, r, etag) {
+		return
+	}
+
+	cacheKey := h.redis.TenantKey(tenantID, "dashboard", jobID.String()) + fmt.Sprintf(":exc-heatmap:%s:%d", bucket, limit)
+	resp, cachedAt, err := readLive(r.Context(), h.redis, cacheKey, func(ctx context.Context) (*domain.ErrorHeatmapResponse, error) {
+		return h.ch.GetErrorHeatmap(ctx, tenantID, jobID.String(), bucket, limit)
+	})
+	if errors.Is(err, errClickHouseUnavailable) {
+		clickHouseUnavailable(w, "error heatmap")
+		return
+	}
+	if err != nil {
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to compute error heatmap")
+		return
+	}
+
+	writeLiveSection(w, etag, resp, cachedAt)
+}
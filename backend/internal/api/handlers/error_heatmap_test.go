@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestErrorHeatmapHandler_ServeHTTP(t *testing.T) {
+	tenantID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	jobID := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	now := time.Now()
+	baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+
+	completeJob := &domain.AnalysisJob{
+		ID:        jobID,
+		TenantID:  tenantID,
+		Status:    domain.JobStatusComplete,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	parsingJob := &domain.AnalysisJob{
+		ID:        jobID,
+		TenantID:  tenantID,
+		Status:    domain.JobStatusParsing,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	bucketStart := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	sampleResponse := &domain.ErrorHeatmapResponse{
+		BucketSize: "1 MINUTE",
+		Buckets:    []domain.Timestamp{domain.NewTimestamp(bucketStart), domain.NewTimestamp(bucketStart.Add(time.Minute))},
+		Rows: []domain.ErrorHeatmapRow{
+			{ErrorCode: "ARERR-500", Counts: []int64{1, 4}, Total: 5, PeakBucket: 1},
+		},
+		TotalCount: 5,
+	}
+
+	cachedJSON, err := json.Marshal(sampleResponse)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		jobIDStr       string
+		query          string
+		setupMocks     func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache)
+		expectedStatus int
+		checkBody      func(t *testing.T, body []byte)
+	}{
+		{
+			name:     "cache_hit_returns_200_with_cached_data",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10").Return(string(cachedJSON), nil)
+			},
+			expectedStatus: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var resp domain.ErrorHeatmapResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, int64(5), resp.TotalCount)
+				require.Len(t, resp.Rows, 1)
+				assert.Equal(t, 1, resp.Rows[0].PeakBucket)
+			},
+		},
+		{
+			name:     "cache_miss_queries_clickhouse_and_caches",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			query:    "?bucket=5m&limit=3",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:5m:3").Return("", errors.New("cache miss"))
+				ch.On("GetErrorHeatmap", mock.Anything, tenantID.String(), jobID.String(), "5m", 3).Return(sampleResponse, nil)
+				redis.On("Set", mock.Anything, baseKey+":exc-heatmap:5m:3", sampleResponse, sectionCacheTTL).Return(nil)
+				redis.On("Set", mock.Anything, baseKey+":exc-heatmap:5m:3:stale", mock.Anything, staleSectionTTL).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var resp domain.ErrorHeatmapResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Len(t, resp.Buckets, 2)
+				assert.Equal(t, []int64{1, 4}, resp.Rows[0].Counts)
+			},
+		},
+		{
+			name:     "out_of_range_limit_uses_default",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			query:    "?limit=500",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10").Return("", errors.New("cache miss"))
+				ch.On("GetErrorHeatmap", mock.Anything, tenantID.String(), jobID.String(), "auto", 10).Return(sampleResponse, nil)
+				redis.On("Set", mock.Anything, baseKey+":exc-heatmap:auto:10", sampleResponse, sectionCacheTTL).Return(nil)
+				redis.On("Set", mock.Anything, baseKey+":exc-heatmap:auto:10:stale", mock.Anything, staleSectionTTL).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "clickhouse_error_returns_500",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10").Return("", errors.New("cache miss"))
+				ch.On("GetErrorHeatmap", mock.Anything, tenantID.String(), jobID.String(), "auto", 10).Return(nil, errors.New("boom"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			checkBody: func(t *testing.T, body []byte) {
+				assert.Contains(t, string(body), "failed to compute error heatmap")
+			},
+		},
+		{
+			name:     "clickhouse_down_serves_stale_copy",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10").Return("", errors.New("cache miss"))
+				ch.On("GetErrorHeatmap", mock.Anything, tenantID.String(), jobID.String(), "auto", 10).Return(nil, errClickHouseDown)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10:stale").
+					Return(fmt.Sprintf(`{"cached_at":"2026-02-03T11:00:00Z","data":%s}`, cachedJSON), nil)
+			},
+			expectedStatus: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var resp struct {
+					domain.ErrorHeatmapResponse
+					Degraded bool      `json:"degraded"`
+					CachedAt time.Time `json:"cached_at"`
+					Reason   string    `json:"reason"`
+				}
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.True(t, resp.Degraded)
+				assert.Equal(t, time.Date(2026, 2, 3, 11, 0, 0, 0, time.UTC), resp.CachedAt)
+				assert.Equal(t, "clickhouse_unavailable", resp.Reason)
+				assert.Equal(t, int64(5), resp.TotalCount)
+			},
+		},
+		{
+			name:     "clickhouse_down_without_cache_returns_503",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10").Return("", errors.New("cache miss"))
+				ch.On("GetErrorHeatmap", mock.Anything, tenantID.String(), jobID.String(), "auto", 10).Return(nil, errClickHouseDown)
+				redis.On("Get", mock.Anything, baseKey+":exc-heatmap:auto:10:stale").Return("", errors.New("cache miss"))
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			checkBody: func(t *testing.T, body []byte) {
+				assert.Contains(t, string(body), `"reason":"clickhouse_unavailable"`)
+			},
+		},
+		{
+			name:     "invalid_bucket_returns_400",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			query:    "?bucket=2h",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+			},
+			expectedStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, body []byte) {
+				assert.Contains(t, string(body), "bucket must be one of")
+			},
+		},
+		{
+			name:     "missing_tenant_returns_401",
+			tenantID: "",
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid_job_id_returns_400",
+			tenantID: tenantID.String(),
+			jobIDStr: "not-a-uuid",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "job_not_found_returns_404",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(nil, fmt.Errorf("not found"))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:     "job_not_complete_returns_409",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(parsingJob, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			redis := new(testutil.MockRedisCache)
+			tc.setupMocks(pg, ch, redis)
+
+			handler := NewErrorHeatmapHandler(pg, ch, redis)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+tc.jobIDStr+"/dashboard/exceptions/heatmap"+tc.query, nil)
+			if tc.tenantID != "" {
+				ctx := middleware.WithTenantID(req.Context(), tc.tenantID)
+				ctx = middleware.WithUserID(ctx, "test-user")
+				req = req.WithContext(ctx)
+			}
+			req = mux.SetURLVars(req, map[string]string{"job_id": tc.jobIDStr})
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w.Body.Bytes())
+			}
+
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+			redis.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// ErrorOnsetHandler serves when an analysed capture started to fail: its
+// first errors, error onset curve and error rate threshold crossings.
+type ErrorOnsetHandler struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+func NewErrorOnsetHandler(pg storage.PostgresStore, ch storage.ClickHouseStore) *ErrorOnsetHandler {
+	return &ErrorOnsetHandler{pg: pg, ch: ch}
+}
+
+// ServeHTTP handles GET /api/v1/analyses/{job_id}/error-onset. The onset is
+// recorded when the analysis completes; analyses completed before it was
+// recorded have it computed on first request and saved.
+func (h *ErrorOnsetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	if job.Status != domain.JobStatusComplete {
+		sectionNotComplete(w)
+		return
+	}
+
+	etag := sectionETag(job, "error-onset")
+	if sectionNotModified(w, r, etag) {
+		return
+	}
+
+	onset, err := h.pg.GetJobErrorOnset(r.Context(), tid, jobID)
+	if err != nil {
+		slog.Error("failed to get error onset", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve error onset")
+		return
+	}
+	if onset == nil {
+		onset, err = h.ch.GetErrorOnset(r.Context(), tenantID, jobID.String())
+		if err != nil {
+			slog.Error("failed to compute error onset", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to compute error onset")
+			return
+		}
+		if err := h.pg.UpdateJobErrorOnset(r.Context(), tid, jobID, onset); err != nil {
+			slog.Warn("failed to save computed error onset", "job_id", jobID, "error", err)
+		}
+	}
+
+	writeSection(w, etag, onset, true)
+}
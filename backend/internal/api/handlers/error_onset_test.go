@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestErrorOnsetHandler_ServeHTTP(t *testing.T) {
+	firstErr := time.Date(2026, 2, 10, 9, 12, 0, 0, time.UTC)
+	completeJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	onset := &domain.ErrorOnset{
+		JobID:       fixedJobID.String(),
+		TotalErrors: 12,
+		FirstError:  &domain.FirstError{Key: "API", Timestamp: domain.NewTimestamp(firstErr), EntryID: "e-1", LineNumber: 420},
+		ByLogType:   []domain.FirstError{{Key: "API", Timestamp: domain.NewTimestamp(firstErr), EntryID: "e-1", LineNumber: 420, ErrorCount: 12}},
+		Thresholds:  []domain.ErrorRateCrossing{{Threshold: 0.01, CrossedAt: domain.TimestampPtr(&firstErr), Rate: 0.02}},
+	}
+
+	tests := []struct {
+		name       string
+		jobID      string
+		setupMocks func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore)
+		wantStatus int
+		checkBody  func(t *testing.T, resp domain.ErrorOnset)
+	}{
+		{
+			name: "returns the recorded onset",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("GetJobErrorOnset", mock.Anything, fixedTenantID, fixedJobID).Return(onset, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, resp domain.ErrorOnset) {
+				require.NotNil(t, resp.FirstError)
+				assert.Equal(t, "e-1", resp.FirstError.EntryID)
+				assert.True(t, resp.FirstError.Timestamp.Equal(firstErr))
+				require.Len(t, resp.Thresholds, 1)
+				assert.NotNil(t, resp.Thresholds[0].CrossedAt)
+			},
+		},
+		{
+			name: "computes and saves a missing onset",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("GetJobErrorOnset", mock.Anything, fixedTenantID, fixedJobID).Return(nil, nil)
+				ch.On("GetErrorOnset", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(onset, nil)
+				pg.On("UpdateJobErrorOnset", mock.Anything, fixedTenantID, fixedJobID, onset).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, resp domain.ErrorOnset) {
+				assert.Equal(t, int64(12), resp.TotalErrors)
+			},
+		},
+		{
+			name: "a failed save still returns the onset",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("GetJobErrorOnset", mock.Anything, fixedTenantID, fixedJobID).Return(nil, nil)
+				ch.On("GetErrorOnset", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(onset, nil)
+				pg.On("UpdateJobErrorOnset", mock.Anything, fixedTenantID, fixedJobID, onset).Return(errors.New("db down"))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "clickhouse failure",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("GetJobErrorOnset", mock.Anything, fixedTenantID, fixedJobID).Return(nil, nil)
+				ch.On("GetErrorOnset", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(nil, errors.New("timeout"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "job still running",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, Status: domain.JobStatusParsing}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "job not found",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid job_id",
+			jobID:      "nope",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			tc.setupMocks(pg, ch)
+
+			jobID := tc.jobID
+			if jobID == "" {
+				jobID = fixedJobID.String()
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analyses/"+jobID+"/error-onset", nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": jobID})
+			w := httptest.NewRecorder()
+			NewErrorOnsetHandler(pg, ch).ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				var resp domain.ErrorOnset
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				tc.checkBody(t, resp)
+			}
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// sectionMaxAge is how long browsers may reuse a completed section payload
+// before revalidating it with If-None-Match.
+const sectionMaxAge = time.Hour
+
+// sectionETag returns the strong ETag of one section of a completed job.
+// Section payloads are immutable once a job completes, so the tag is derived
+// from the job rather than the body: a reprocess changes the completion
+// time and a parser change bumps worker.ParserVersion, both yielding a new
+// tag.
+func sectionETag(job *domain.AnalysisJob, section string) string {
+	var completed int64
+	if job.CompletedAt != nil {
+		completed = job.CompletedAt.UnixNano()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s", job.ID, completed, worker.ParserVersion, section)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// sectionNotModified answers a conditional request for a completed job's
+// section. When If-None-Match matches etag it writes 304 Not Modified and
+// returns true, letting the handler skip loading the payload.
+func sectionNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	setSectionCacheHeaders(w, etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// writeSection writes a section payload. Complete payloads carry the ETag
+// and may be cached privately; partial ones, such as empty fallbacks served
+// while the section is missing from Redis, are marked no-store so clients
+// fetch the real data once it is available.
+func writeSection(w http.ResponseWriter, etag string, data any, complete bool) {
+	if complete {
+		setSectionCacheHeaders(w, etag)
+	} else {
+		noStore(w)
+	}
+	api.JSON(w, http.StatusOK, data)
+}
+
+// sectionNotComplete rejects a section request for a job that is still
+// running or failed. The response must not be cached since it will change.
+func sectionNotComplete(w http.ResponseWriter) {
+	noStore(w)
+	api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+}
+
+func setSectionCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(sectionMaxAge.Seconds())))
+}
+
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
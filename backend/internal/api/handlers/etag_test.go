@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+func sectionRequest(path, ifNoneMatch string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String()})
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	return injectAuth(req, fixedTenantID.String())
+}
+
+func etagJob() *domain.AnalysisJob {
+	job := completedJob(fixedTenantID, fixedJobID)
+	completed := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	job.CompletedAt = &completed
+	return job
+}
+
+func TestDashboardHandler_ETag(t *testing.T) {
+	cached, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+
+	pg, ch, redis := newDashboardMocks()
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(etagJob(), nil)
+	redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return("t:dashboard:j")
+	redis.On("Get", mock.Anything, "t:dashboard:j").Return(string(cached), nil).Twice()
+	h := NewDashboardHandler(pg, ch, redis)
+
+	// Two unconditional requests return the same ETag.
+	w1 := httptest.NewRecorder()
+	h.ServeHTTP(w1, sectionRequest("/api/v1/analysis/x/dashboard", ""))
+	require.Equal(t, http.StatusOK, w1.Code)
+	etag := w1.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "private, max-age=3600", w1.Header().Get("Cache-Control"))
+
+	w2 := httptest.NewRecorder()
+	h.ServeHTTP(w2, sectionRequest("/api/v1/analysis/x/dashboard", ""))
+	assert.Equal(t, etag, w2.Header().Get("ETag"))
+
+	// A matching If-None-Match is answered without touching Redis.
+	for _, inm := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, sectionRequest("/api/v1/analysis/x/dashboard", inm))
+		assert.Equal(t, http.StatusNotModified, w.Code, inm)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Empty(t, w.Body.Bytes())
+	}
+	redis.AssertNumberOfCalls(t, "Get", 2)
+}
+
+func TestSectionETag_Busting(t *testing.T) {
+	job := etagJob()
+	etag := sectionETag(job, "aggregates")
+	assert.NotEqual(t, etag, sectionETag(job, "exceptions"), "sections have distinct tags")
+
+	reprocessed := *job
+	later := job.CompletedAt.Add(time.Hour)
+	reprocessed.CompletedAt = &later
+	assert.NotEqual(t, etag, sectionETag(&reprocessed, "aggregates"))
+
+	defer func(v string) { worker.ParserVersion = v }(worker.ParserVersion)
+	worker.ParserVersion += "-next"
+	bumped := sectionETag(job, "aggregates")
+	assert.NotEqual(t, etag, bumped)
+
+	// A client revalidating with the old tag gets the full payload again.
+	pg, ch, redis := newDashboardMocks()
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+	redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return("t:dashboard:j")
+	redis.On("Get", mock.Anything, "t:dashboard:j:agg").Return(`{"source":"jar_parsed"}`, nil)
+
+	w := httptest.NewRecorder()
+	NewAggregatesHandler(pg, ch, redis).ServeHTTP(w, sectionRequest("/api/v1/analysis/x/dashboard/aggregates", etag))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, bumped, w.Header().Get("ETag"))
+}
+
+func TestSectionCaching_Disabled(t *testing.T) {
+	t.Run("job not complete", func(t *testing.T) {
+		pg, ch, redis := newDashboardMocks()
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsingJob(fixedTenantID, fixedJobID), nil)
+
+		w := httptest.NewRecorder()
+		NewExceptionsHandler(pg, ch, redis).ServeHTTP(w, sectionRequest("/api/v1/analysis/x/dashboard/exceptions", "*"))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Empty(t, w.Header().Get("ETag"))
+	})
+
+	t.Run("partial section", func(t *testing.T) {
+		pg, ch, redis := newDashboardMocks()
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(etagJob(), nil)
+		redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return("t:dashboard:j")
+		redis.On("Get", mock.Anything, "t:dashboard:j:queued").Return("", nil)
+
+		w := httptest.NewRecorder()
+		NewQueuedCallsHandler(pg, ch, redis).ServeHTTP(w, sectionRequest("/api/v1/analysis/x/dashboard/queued-calls", ""))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Empty(t, w.Header().Get("ETag"))
+	})
+}
@@ -1,67 +1,14 @@
 package handlers
 
 import (
-	"net/http"
-
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 type ExceptionsHandler struct {
-	pg    storage.PostgresStore
-	ch    storage.ClickHouseStore
-	redis storage.RedisCache
+	*sectionHandler[domain.ExceptionsResponse]
 }
 
 func NewExceptionsHandler(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *ExceptionsHandler {
-	return &ExceptionsHandler{pg: pg, ch: ch, redis: redis}
-}
-
-func (h *ExceptionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	tenantID := middleware.GetTenantID(r.Context())
-	if tenantID == "" {
-		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
-		return
-	}
-
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
-		return
-	}
-
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
-		return
-	}
-
-	job, err := h.pg.GetJob(r.Context(), tid, jobID)
-	if err != nil {
-		if storage.IsNotFound(err) {
-			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
-		} else {
-			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
-		}
-		return
-	}
-
-	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
-		return
-	}
-
-	data, err := getOrComputeExceptions(r.Context(), h.redis, tenantID, jobID.String())
-	if err != nil {
-		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "exceptions data not available")
-		return
-	}
-
-	api.JSON(w, http.StatusOK, data)
+	return &ExceptionsHandler{newSectionHandler(pg, ch, redis, exceptionsSection)}
 }
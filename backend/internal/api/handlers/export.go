@@ -55,33 +55,25 @@ func (h *ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var timeFrom, timeTo *time.Time
-	if fromStr := r.URL.Query().Get("time_from"); fromStr != "" {
-		t, err := time.Parse(time.RFC3339, fromStr)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid time_from format, expected RFC3339")
-			return
-		}
-		timeFrom = &t
+	bounds, ok := parseTimeBounds(w, r.URL.Query(), "time_from", "time_to")
+	if !ok {
+		return
 	}
-	if toStr := r.URL.Query().Get("time_to"); toStr != "" {
-		t, err := time.Parse(time.RFC3339, toStr)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid time_to format, expected RFC3339")
-			return
-		}
-		timeTo = &t
+	timeFrom, timeTo, ok := resolveTimeBounds(w, r, h.ch, tenantID, jobID, bounds)
+	if !ok {
+		return
 	}
 
 	searchQuery := storage.SearchQuery{
-		Query:      query,
-		Page:       1,
-		PageSize:   limit,
-		SortBy:     "timestamp",
-		SortOrder:  "asc",
-		TimeFrom:   timeFrom,
-		TimeTo:     timeTo,
-		ExportMode: true,
+		Query:        query,
+		Page:         1,
+		PageSize:     limit,
+		SortBy:       "timestamp",
+		SortOrder:    "asc",
+		TimeFrom:     timeFrom,
+		TimeTo:       timeTo,
+		ExportMode:   true,
+		IncludeNoise: r.URL.Query().Get("include_noise") == "true",
 	}
 
 	result, err := h.ch.SearchEntries(r.Context(), tenantID, jobID, searchQuery)
@@ -94,6 +86,13 @@ func (h *ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("X-Total-Count", strconv.FormatInt(result.TotalCount, 10))
 	w.Header().Set("X-Exported-Count", strconv.Itoa(len(result.Entries)))
+	// The resolved time bounds, for clients that passed capture expressions.
+	if timeFrom != nil {
+		w.Header().Set("X-Time-From", timeFrom.UTC().Format(time.RFC3339Nano))
+	}
+	if timeTo != nil {
+		w.Header().Set("X-Time-To", timeTo.UTC().Format(time.RFC3339Nano))
+	}
 
 	if format == "csv" {
 		h.exportCSV(w, result.Entries, filename)
@@ -3,9 +3,6 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
@@ -29,16 +26,13 @@ func (h *FileMetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
 
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+	tid, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -53,15 +47,20 @@ func (h *FileMetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+		sectionNotComplete(w)
+		return
+	}
+
+	etag := sectionETag(job, "file-metadata")
+	if sectionNotModified(w, r, etag) {
 		return
 	}
 
-	data, err := getOrComputeFileMetadata(r.Context(), h.redis, tenantID, jobID.String())
+	data, found, err := getOrComputeFileMetadata(r.Context(), h.redis, tenantID, jobID.String())
 	if err != nil {
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "file metadata not available")
 		return
 	}
 
-	api.JSON(w, http.StatusOK, data)
+	writeSection(w, etag, data, found)
 }
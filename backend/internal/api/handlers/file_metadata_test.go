@@ -46,15 +46,15 @@ func TestFileMetadataHandler_ServeHTTP(t *testing.T) {
 			{
 				FileNumber: 1,
 				FileName:   "arserver_20260203.log",
-				StartTime:  time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC),
-				EndTime:    time.Date(2026, 2, 3, 14, 0, 0, 0, time.UTC),
+				StartTime:  domain.NewTimestamp(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)),
+				EndTime:    domain.NewTimestamp(time.Date(2026, 2, 3, 14, 0, 0, 0, time.UTC)),
 				DurationMS: 14400000,
 			},
 			{
 				FileNumber: 2,
 				FileName:   "arserver_20260204.log",
-				StartTime:  time.Date(2026, 2, 4, 8, 0, 0, 0, time.UTC),
-				EndTime:    time.Date(2026, 2, 4, 16, 30, 0, 0, time.UTC),
+				StartTime:  domain.NewTimestamp(time.Date(2026, 2, 4, 8, 0, 0, 0, time.UTC)),
+				EndTime:    domain.NewTimestamp(time.Date(2026, 2, 4, 16, 30, 0, 0, time.UTC)),
 				DurationMS: 30600000,
 			},
 		},
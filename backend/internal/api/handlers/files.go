@@ -3,8 +3,6 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/google/uuid"
-
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
@@ -28,9 +26,8 @@ func (h *FileHandlers) ListFiles() http.Handler {
 			return
 		}
 
-		tid, err := uuid.Parse(tenantID)
-		if err != nil {
-			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+		tid, ok := requestTenant(w, r)
+		if !ok {
 			return
 		}
 
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// Sources of the report of a captured fixture.
+const (
+	fixtureSourceStored = "stored" // the text report kept by the worker
+	fixtureSourceRerun  = "rerun"  // the JAR run again on the uploaded log
+)
+
+// captureFixtureRequest is the optional body of
+// POST /api/v1/admin/analyses/{job_id}/capture-fixture. Redact defaults to
+// every kind of value, MaxRows to jar.DefaultFixtureTableRows.
+type captureFixtureRequest struct {
+	MaxRows int                `json:"max_rows"`
+	Redact  *jar.RedactOptions `json:"redact"`
+}
+
+// fixtureManifest records how a fixture was captured, next to it in the
+// bundle.
+type fixtureManifest struct {
+	JobID         uuid.UUID         `json:"job_id"`
+	Source        string            `json:"source"`
+	ParserVersion string            `json:"parser_version"`
+	MaxTableRows  int               `json:"max_table_rows"`
+	Redact        jar.RedactOptions `json:"redact"`
+	Redactions    map[string]int    `json:"redactions"`
+	ParseError    string            `json:"parse_error,omitempty"`
+	CapturedAt    time.Time         `json:"captured_at"`
+}
+
+// FixtureCaptureHandler serves POST /api/v1/admin/analyses/{job_id}/capture-fixture:
+// the JAR text report of an analysis, cut to the first rows of each table
+// and redacted, with the snapshot of what the current parser makes of it,
+// as a ZIP bundle to drop into testdata/parser_regressions.
+//
+// The report is the one the worker kept when JAR_STORE_OUTPUT is on. For
+// other analyses the JAR is run again on the uploaded log, which takes as
+// long as the analysis did.
+type FixtureCaptureHandler struct {
+	pg      storage.PostgresStore
+	objects storage.ObjectStorage
+	runner  worker.JARRunner
+}
+
+// NewFixtureCaptureHandler creates the handler. runner may be nil, in which
+// case only analyses whose report was kept can be captured.
+func NewFixtureCaptureHandler(pg storage.PostgresStore, objects storage.ObjectStorage, runner worker.JARRunner) *FixtureCaptureHandler {
+	return &FixtureCaptureHandler{pg: pg, objects: objects, runner: runner}
+}
+
+func (h *FixtureCaptureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	var req captureFixtureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+	if req.MaxRows < 0 {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "max_rows must not be negative")
+		return
+	}
+	opts := jar.FixtureOptions{Redact: jar.RedactAll, MaxTableRows: req.MaxRows}
+	if req.Redact != nil {
+		opts.Redact = *req.Redact
+	}
+	if opts.MaxTableRows == 0 {
+		opts.MaxTableRows = jar.DefaultFixtureTableRows
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	source := fixtureSourceStored
+	report, err := h.storedReport(r.Context(), job)
+	if err != nil {
+		slog.Error("capture fixture: read stored JAR output", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to read the stored JAR output")
+		return
+	}
+	if report == "" {
+		if h.runner == nil {
+			api.Error(w, http.StatusConflict, api.ErrCodeConflict, "no JAR output stored for this analysis and no JAR to run")
+			return
+		}
+		// The JAR runs for as long as the analysis did; the server's write
+		// timeout would cut the response off.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		source = fixtureSourceRerun
+		if report, err = h.rerunReport(r.Context(), job); err != nil {
+			slog.Error("capture fixture: re-run JAR", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to re-run the JAR")
+			return
+		}
+	}
+
+	fixture, err := jar.CaptureFixture(report, opts)
+	if err != nil {
+		slog.Error("capture fixture", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to capture fixture")
+		return
+	}
+	manifest := fixtureManifest{
+		JobID:         jobID,
+		Source:        source,
+		ParserVersion: worker.ParserVersion,
+		MaxTableRows:  opts.MaxTableRows,
+		Redact:        opts.Redact,
+		Redactions:    fixture.Redactions,
+		ParseError:    fixture.ParseError,
+		CapturedAt:    time.Now().UTC(),
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"parser-fixture-%s.zip\"", jobID))
+	if err := writeFixtureZIP(w, jobID.String(), fixture, manifest); err != nil {
+		slog.Error("capture fixture: write bundle", "job_id", jobID, "error", err)
+	}
+}
+
+// storedReport returns the text report the worker kept for job, or an empty
+// string if there is none.
+func (h *FixtureCaptureHandler) storedReport(ctx context.Context, job *domain.AnalysisJob) (string, error) {
+	key := worker.JAROutputKey(job.TenantID.String(), job.ID.String())
+	ok, err := h.objects.Exists(ctx, key)
+	if err != nil || !ok {
+		return "", err
+	}
+	rc, err := h.objects.Download(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// rerunReport runs the JAR on the uploaded log of job again, asking for the
+// text report whatever format the analysis used.
+func (h *FixtureCaptureHandler) rerunReport(ctx context.Context, job *domain.AnalysisJob) (string, error) {
+	file, err := h.pg.GetLogFile(ctx, job.TenantID, job.FileID)
+	if err != nil {
+		return "", fmt.Errorf("get log file: %w", err)
+	}
+	rc, err := h.objects.Download(ctx, file.S3Key)
+	if err != nil {
+		return "", fmt.Errorf("download log file: %w", err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "remedyiq-fixture-*.log")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("download log file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	flags := job.JARFlags
+	flags.OutputFormat = ""
+	result, err := h.runner.Run(ctx, tmp.Name(), flags, job.JVMHeapMB, nil)
+	if err != nil {
+		return "", err
+	}
+	return result.Stdout, nil
+}
+
+// writeFixtureZIP writes the fixture bundle: the report and the parse
+// snapshot named as the regression suite expects, and the manifest.
+func writeFixtureZIP(w io.Writer, name string, f *jar.Fixture, manifest fixtureManifest) error {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{name + jar.FixtureReportSuffix, []byte(f.Report)},
+		{name + jar.FixtureSnapshotSuffix, f.Snapshot},
+		{name + ".capture.json", append(manifestJSON, '\n')},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: manifest.CapturedAt})
+		if err != nil {
+			return fmt.Errorf("zip %s: %w", file.name, err)
+		}
+		if _, err := fw.Write(file.data); err != nil {
+			return fmt.Errorf("zip %s: %w", file.name, err)
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// readFixtureZIP returns the files of a fixture bundle by name.
+func readFixtureZIP(t *testing.T, w *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestFixtureCaptureHandler(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "..", "testdata", "jar_output_log1.txt"))
+	require.NoError(t, err)
+	report := string(content)
+
+	outputKey := worker.JAROutputKey(fixedTenantID.String(), fixedJobID.String())
+	job := completedJob(fixedTenantID, fixedJobID)
+	job.FileID = fixedFileID
+	job.JARFlags.OutputFormat = domain.JAROutputXML
+	base := fixedJobID.String()
+
+	tests := []struct {
+		name       string
+		body       any
+		noRunner   bool
+		setupMocks func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage)
+		wantStatus int
+		check      func(t *testing.T, files map[string]string, runner *testutil.ReplayJARRunner)
+	}{
+		{
+			name: "stored output",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+				s3.On("Exists", mock.Anything, outputKey).Return(true, nil)
+				s3.On("Download", mock.Anything, outputKey).Return(io.NopCloser(strings.NewReader(report)), nil)
+			},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, files map[string]string, runner *testutil.ReplayJARRunner) {
+				require.Len(t, files, 3)
+				assert.Empty(t, runner.Calls(), "the stored report is used")
+				assert.NotContains(t, files[base+".txt"], "ITSMAPP1.citc.gov.sa")
+
+				result, err := jar.ParseOutputWithOptions(files[base+".txt"], jar.ParseOptions{Strict: true})
+				require.NoError(t, err)
+				want, err := json.MarshalIndent(result, "", "  ")
+				require.NoError(t, err)
+				assert.JSONEq(t, string(want), files[base+".parse.json"], "the snapshot is of the redacted report")
+				assert.Len(t, result.Dashboard.TopAPICalls, jar.DefaultFixtureTableRows)
+
+				var manifest map[string]any
+				require.NoError(t, json.Unmarshal([]byte(files[base+".capture.json"]), &manifest))
+				assert.Equal(t, "stored", manifest["source"])
+				assert.Equal(t, worker.ParserVersion, manifest["parser_version"])
+				assert.EqualValues(t, jar.DefaultFixtureTableRows, manifest["max_table_rows"])
+				assert.Equal(t, map[string]any{"users": true, "request_ids": true, "hosts": true, "literals": true}, manifest["redact"])
+				assert.NotContains(t, manifest, "parse_error")
+			},
+		},
+		{
+			name: "re-runs the JAR without stored output",
+			body: map[string]any{"max_rows": 2, "redact": map[string]bool{"hosts": true}},
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+				s3.On("Exists", mock.Anything, outputKey).Return(false, nil)
+				pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).Return(&domain.LogFile{S3Key: "tenants/t/files/f.log"}, nil)
+				s3.On("Download", mock.Anything, "tenants/t/files/f.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+			},
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, files map[string]string, runner *testutil.ReplayJARRunner) {
+				calls := runner.Calls()
+				require.Len(t, calls, 1)
+				assert.Empty(t, calls[0].Flags.OutputFormat, "the text report is asked for")
+				assert.Equal(t, job.JVMHeapMB, calls[0].HeapMB)
+
+				text := files[base+".txt"]
+				assert.NotContains(t, text, "ITSMAPP1.citc.gov.sa")
+				assert.Contains(t, text, "Remedy Application Service", "only hosts are redacted")
+				result, err := jar.ParseOutput(text)
+				require.NoError(t, err)
+				assert.Len(t, result.Dashboard.TopAPICalls, 2)
+
+				var manifest fixtureManifest
+				require.NoError(t, json.Unmarshal([]byte(files[base+".capture.json"]), &manifest))
+				assert.Equal(t, fixtureSourceRerun, manifest.Source)
+				assert.Equal(t, jar.RedactOptions{Hosts: true}, manifest.Redact)
+				assert.Equal(t, 2, manifest.MaxTableRows)
+			},
+		},
+		{
+			name:     "no stored output and no JAR",
+			noRunner: true,
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+				s3.On("Exists", mock.Anything, outputKey).Return(false, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "stored output unreadable",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+				s3.On("Exists", mock.Anything, outputKey).Return(false, errors.New("s3 unavailable"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "job not found",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "negative max_rows",
+			body:       map[string]any{"max_rows": -1},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			s3 := new(testutil.MockObjectStorage)
+			if tt.setupMocks != nil {
+				tt.setupMocks(pg, s3)
+			}
+			runner := testutil.NewReplayJARRunner(report)
+			h := NewFixtureCaptureHandler(pg, s3, runner)
+			if tt.noRunner {
+				h = NewFixtureCaptureHandler(pg, s3, nil)
+			}
+
+			req := newTestRequest(http.MethodPost, "/api/v1/admin/analyses/"+base+"/capture-fixture").
+				tenant(fixedTenantID.String()).
+				vars("job_id", base)
+			if tt.body != nil {
+				req = req.jsonBody(t, tt.body)
+			}
+			w := req.serve(h)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.check != nil {
+				assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="parser-fixture-`+base+`.zip"`, w.Header().Get("Content-Disposition"))
+				tt.check(t, readFixtureZIP(t, w), runner)
+			}
+			pg.AssertExpectations(t)
+			s3.AssertExpectations(t)
+		})
+	}
+}
+
+func TestFixtureCaptureHandler_InvalidRequest(t *testing.T) {
+	h := NewFixtureCaptureHandler(new(testutil.MockPostgresStore), new(testutil.MockObjectStorage), nil)
+
+	w := newTestRequest(http.MethodPost, "/").vars("job_id", fixedJobID.String()).serve(h)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = newTestRequest(http.MethodPost, "/").tenant(fixedTenantID.String()).vars("job_id", "nope").serve(h)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := newTestRequest(http.MethodPost, "/").tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String())
+	req.body = strings.NewReader("{")
+	w = req.serve(h)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,67 +1,42 @@
 package handlers
 
 import (
-	"net/http"
+	"context"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 type GapsHandler struct {
-	pg    storage.PostgresStore
-	ch    storage.ClickHouseStore
-	redis storage.RedisCache
+	*sectionHandler[domain.GapsResponse]
 }
 
 func NewGapsHandler(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *GapsHandler {
-	return &GapsHandler{pg: pg, ch: ch, redis: redis}
+	h := newSectionHandler(pg, ch, redis, gapsSection)
+	h.decorate = labelRestartGaps
+	h.overlay = setGapsOverlays
+	return &GapsHandler{h}
 }
 
-func (h *GapsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	tenantID := middleware.GetTenantID(r.Context())
-	if tenantID == "" {
-		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
-		return
-	}
-
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
-		return
-	}
-
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
-		return
-	}
-
-	job, err := h.pg.GetJob(r.Context(), tid, jobID)
-	if err != nil {
-		if storage.IsNotFound(err) {
-			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
-		} else {
-			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
-		}
-		return
-	}
-
-	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
-		return
+// labelRestartGaps marks the gaps that are the downtime of a restart
+// detected in the job's capture.
+func labelRestartGaps(_ context.Context, job *domain.AnalysisJob, data any) {
+	switch gaps := data.(type) {
+	case *domain.GapsResponse:
+		storage.LabelRestartGaps(gaps, job.Restarts)
+	case *domain.JARGapsResponse:
+		storage.LabelRestartJARGaps(gaps, job.Restarts)
 	}
+}
 
-	data, err := getOrComputeGaps(r.Context(), h.redis, tenantID, jobID.String())
-	if err != nil {
-		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "gaps data not available")
-		return
+// setGapsOverlays sets the overlays of the job's incident group on its
+// gaps, so that a stall can be read against the GC pauses of the same
+// server.
+func setGapsOverlays(data any, overlays []domain.SourceOverlay) {
+	switch gaps := data.(type) {
+	case *domain.GapsResponse:
+		gaps.Overlays = overlays
+	case *domain.JARGapsResponse:
+		gaps.Overlays = overlays
 	}
-
-	api.JSON(w, http.StatusOK, data)
 }
@@ -44,8 +44,8 @@ func TestGapsHandler_ServeHTTP(t *testing.T) {
 	sampleResponse := &domain.GapsResponse{
 		Gaps: []domain.GapEntry{
 			{
-				StartTime:  now.Add(-10 * time.Minute),
-				EndTime:    now.Add(-5 * time.Minute),
+				StartTime:  domain.NewTimestamp(now.Add(-10 * time.Minute)),
+				EndTime:    domain.NewTimestamp(now.Add(-5 * time.Minute)),
 				DurationMS: 300000,
 				BeforeLine: 100,
 				AfterLine:  200,
@@ -86,6 +86,29 @@ func TestGapsHandler_ServeHTTP(t *testing.T) {
 				assert.Len(t, resp.QueueHealth, 1)
 			},
 		},
+		{
+			name:     "gap_at_a_restart_is_labelled",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				restarted := *completeJob
+				restarted.Restarts = []domain.RestartEvent{{Timestamp: sampleResponse.Gaps[0].EndTime, Confidence: 0.95}}
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(&restarted, nil)
+				baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":gaps").Return(string(cachedJSON), nil)
+			},
+			expectedStatus: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var resp domain.GapsResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				require.Len(t, resp.Gaps, 1)
+				require.NotNil(t, resp.Gaps[0].Restart)
+				require.NotNil(t, resp.Gaps[0].Hint)
+				assert.Equal(t, domain.GapServerRestart, resp.Gaps[0].Hint.Classification)
+				assert.Len(t, resp.Restarts, 1)
+			},
+		},
 		{
 			name:     "cache_miss_returns_500",
 			tenantID: tenantID.String(),
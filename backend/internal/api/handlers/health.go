@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 // Version is set at build time via -ldflags. Defaults to "0.1.0-dev".
@@ -24,6 +25,19 @@ type HealthResponse struct {
 	Status   string                   `json:"status"`
 	Version  string                   `json:"version"`
 	Services map[string]ServiceStatus `json:"services"`
+
+	// ReadMode is how the dashboard sections read from ClickHouse are
+	// served: "live", or "cached" while ClickHouse is unhealthy and they
+	// fall back to their last cached copy.
+	ReadMode string `json:"read_mode"`
+
+	// Coalescing counts the dashboard reads that computed their section
+	// and those that shared an identical read in flight.
+	Coalescing CoalescingStats `json:"coalescing"`
+
+	// ResultCache counts the ClickHouse statements of cacheable reads by
+	// how they were served. It is left out when the cache is disabled.
+	ResultCache *storage.ResultCacheStats `json:"result_cache,omitempty"`
 }
 
 // PingFunc is the signature for a function that checks connectivity to a
@@ -34,7 +48,8 @@ type PingFunc func(ctx context.Context) error
 // connectivity to PostgreSQL, ClickHouse, NATS, and Redis and reports
 // individual and aggregate health status.
 type HealthHandler struct {
-	pings map[string]PingFunc
+	pings       map[string]PingFunc
+	resultCache func() storage.ResultCacheStats
 }
 
 // NewHealthHandler creates a HealthHandler with ping functions for each
@@ -57,6 +72,12 @@ func NewHealthHandler(pgPing, chPing, natsPing, redisPing PingFunc) *HealthHandl
 	return &HealthHandler{pings: pings}
 }
 
+// SetResultCacheStats reports the counts of the ClickHouse result cache
+// returned by stats with every health check.
+func (h *HealthHandler) SetResultCacheStats(stats func() storage.ResultCacheStats) {
+	h.resultCache = stats
+}
+
 // ServeHTTP handles the health check request. It pings all configured
 // services concurrently and returns 200 when all are healthy or 503 when
 // any critical service (PostgreSQL, ClickHouse) is down.
@@ -116,8 +137,17 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := HealthResponse{
-		Version:  Version,
-		Services: services,
+		Version:    Version,
+		Services:   services,
+		ReadMode:   "live",
+		Coalescing: ReadCoalescingStats(),
+	}
+	if services["clickhouse"].Status == "unhealthy" {
+		resp.ReadMode = "cached"
+	}
+	if h.resultCache != nil {
+		stats := h.resultCache()
+		resp.ResultCache = &stats
 	}
 
 	if overallHealthy {
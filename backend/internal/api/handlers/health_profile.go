@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// maxHealthProfileBytes caps the body of a health profile update.
+const maxHealthProfileBytes = 64 * 1024
+
+// HealthProfileHandler serves GET, PUT and DELETE
+// /api/v1/admin/tenants/{tenant_id}/health-profile, the weights and
+// breakpoints a tenant's health score is computed with.
+//
+// Every change stores a new profile version, so scores keep pointing at the
+// profile that produced them. PUT and DELETE carry the version the admin
+// last read and fail with 409 if another change was stored since.
+type HealthProfileHandler struct {
+	pg storage.PostgresStore
+}
+
+func NewHealthProfileHandler(pg storage.PostgresStore) *HealthProfileHandler {
+	return &HealthProfileHandler{pg: pg}
+}
+
+// healthProfileRequest is the body of PUT .../health-profile. Version is the
+// profile version the admin last read (0 for the default profile).
+type healthProfileRequest struct {
+	Version    *int                        `json:"version"`
+	Factors    []domain.HealthFactorConfig `json:"factors"`
+	RedBelow   int                         `json:"red_below"`
+	GreenAbove int                         `json:"green_above"`
+}
+
+func (h *HealthProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := pathID(w, r, "tenant_id")
+	if !ok {
+		return
+	}
+	if _, err := h.pg.GetTenant(r.Context(), tenantID); err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+		} else {
+			slog.Error("get tenant failed", "tenant_id", tenantID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve tenant")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, r, tenantID)
+	case http.MethodPut:
+		h.put(w, r, tenantID)
+	case http.MethodDelete:
+		h.reset(w, r, tenantID)
+	default:
+		api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+	}
+}
+
+// get returns the current profile, or the version given by ?version=.
+func (h *HealthProfileHandler) get(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID) {
+	var (
+		profile *domain.HealthProfile
+		err     error
+	)
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, convErr := strconv.Atoi(v)
+		if convErr != nil || version < 0 {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "version must be a non-negative integer")
+			return
+		}
+		if version == 0 {
+			profile = storage.DefaultHealthProfile()
+			profile.TenantID = tenantID
+		} else if profile, err = h.pg.GetHealthProfileVersion(r.Context(), tenantID, version); err != nil && storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "health profile version not found")
+			return
+		}
+	} else {
+		profile, err = storage.LoadHealthProfile(r.Context(), h.pg, tenantID)
+	}
+	if err != nil {
+		slog.Error("get health profile failed", "tenant_id", tenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve health profile")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, profile)
+}
+
+func (h *HealthProfileHandler) put(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID) {
+	var req healthProfileRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHealthProfileBytes)).Decode(&req); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+	if req.Version == nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "version is required")
+		return
+	}
+
+	profile := &domain.HealthProfile{
+		TenantID:   tenantID,
+		Factors:    req.Factors,
+		RedBelow:   req.RedBelow,
+		GreenAbove: req.GreenAbove,
+	}
+	if err := storage.ValidateHealthProfile(profile); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	h.store(w, r, profile, *req.Version)
+}
+
+// reset stores the default profile as a new version. The expected version
+// is given by ?version=.
+func (h *HealthProfileHandler) reset(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID) {
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil || version < 0 {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "version is required")
+		return
+	}
+
+	profile := storage.DefaultHealthProfile()
+	profile.TenantID = tenantID
+	h.store(w, r, profile, version)
+}
+
+func (h *HealthProfileHandler) store(w http.ResponseWriter, r *http.Request, profile *domain.HealthProfile, expectedVersion int) {
+	profile.CreatedBy = middleware.GetUserID(r.Context())
+	if err := h.pg.CreateHealthProfile(r.Context(), profile, expectedVersion); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			api.Error(w, http.StatusConflict, api.ErrCodeConflict, "health profile was changed by someone else; reload and retry")
+			return
+		}
+		slog.Error("create health profile failed", "tenant_id", profile.TenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to save health profile")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, profile)
+}
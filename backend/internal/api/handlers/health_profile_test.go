@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func healthProfileBody(t *testing.T, version int, mutate func(p *domain.HealthProfile)) []byte {
+	t.Helper()
+	p := storage.DefaultHealthProfile()
+	if mutate != nil {
+		mutate(p)
+	}
+	body, err := json.Marshal(map[string]any{
+		"version":     version,
+		"factors":     p.Factors,
+		"red_below":   p.RedBelow,
+		"green_above": p.GreenAbove,
+	})
+	require.NoError(t, err)
+	return body
+}
+
+func TestHealthProfileHandler(t *testing.T) {
+	stored := storage.DefaultHealthProfile()
+	stored.TenantID, stored.Version = fixedTenantID, 2
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       []byte
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "get returns default when none stored",
+			method: http.MethodGet,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetHealthProfile", mock.Anything, fixedTenantID).Return(nil, errors.New("postgres: health profile not found: x"))
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var p domain.HealthProfile
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+				assert.Equal(t, 0, p.Version)
+				assert.Len(t, p.Factors, 4)
+				assert.Equal(t, fixedTenantID, p.TenantID)
+			},
+		},
+		{
+			name:   "get returns latest stored version",
+			method: http.MethodGet,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetHealthProfile", mock.Anything, fixedTenantID).Return(stored, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var p domain.HealthProfile
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+				assert.Equal(t, 2, p.Version)
+			},
+		},
+		{
+			name:   "get historical version",
+			method: http.MethodGet,
+			target: "?version=2",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetHealthProfileVersion", mock.Anything, fixedTenantID, 2).Return(stored, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "get unknown version",
+			method: http.MethodGet,
+			target: "?version=9",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetHealthProfileVersion", mock.Anything, fixedTenantID, 9).Return(nil, errors.New("postgres: health profile not found: x v9"))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "get invalid version",
+			method:     http.MethodGet,
+			target:     "?version=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "put stores next version",
+			method: http.MethodPut,
+			body: healthProfileBody(t, 2, func(p *domain.HealthProfile) {
+				p.Factors[0].Weight, p.Factors[3].Weight = 0.40, 0.10
+			}),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("CreateHealthProfile", mock.Anything, mock.MatchedBy(func(p *domain.HealthProfile) bool {
+					return p.TenantID == fixedTenantID && p.CreatedBy == "test-user" && p.Factors[0].Weight == 0.40
+				}), 2).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.HealthProfile).Version = 3
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var p domain.HealthProfile
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+				assert.Equal(t, 3, p.Version)
+			},
+		},
+		{
+			name:   "put rejects weights not summing to one",
+			method: http.MethodPut,
+			body: healthProfileBody(t, 0, func(p *domain.HealthProfile) {
+				p.Factors[0].Weight = 0.50
+			}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "put requires version",
+			method:     http.MethodPut,
+			body:       []byte(`{"factors":[]}`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "put conflict",
+			method: http.MethodPut,
+			body:   healthProfileBody(t, 1, nil),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("CreateHealthProfile", mock.Anything, mock.Anything, 1).Return(storage.ErrVersionConflict)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "delete resets to default",
+			method: http.MethodDelete,
+			target: "?version=2",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("CreateHealthProfile", mock.Anything, mock.MatchedBy(func(p *domain.HealthProfile) bool {
+					return p.Factors[0].Weight == 0.30 && p.RedBelow == 50
+				}), 2).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "delete requires version",
+			method:     http.MethodDelete,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			pg.On("GetTenant", mock.Anything, fixedTenantID).Return(&domain.Tenant{ID: fixedTenantID}, nil)
+			if tc.setupMocks != nil {
+				tc.setupMocks(pg)
+			}
+
+			req := httptest.NewRequest(tc.method, "/api/v1/admin/tenants/"+fixedTenantID.String()+"/health-profile"+tc.target, bytes.NewReader(tc.body))
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"tenant_id": fixedTenantID.String()})
+			w := httptest.NewRecorder()
+			NewHealthProfileHandler(pg).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHealthProfileHandler_UnknownTenant(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetTenant", mock.Anything, fixedTenantID).Return(nil, errors.New("postgres: tenant not found: x"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/tenants/x/health-profile", nil)
+	req = mux.SetURLVars(req, map[string]string{"tenant_id": fixedTenantID.String()})
+	w := httptest.NewRecorder()
+	NewHealthProfileHandler(pg).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 // ---------------------------------------------------------------------------
@@ -416,3 +418,49 @@ func TestHealthHandler_UnhealthyServiceReportsLatency(t *testing.T) {
 	assert.GreaterOrEqual(t, pgSvc.LatencyMS, int64(0),
 		"unhealthy services must still report non-negative latency")
 }
+
+func TestHealthHandler_ResultCacheStats(t *testing.T) {
+	t.Parallel()
+
+	h := NewHealthHandler(okPing, okPing, nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.NotContains(t, w.Body.String(), "result_cache", "left out when the cache is disabled")
+
+	h.SetResultCacheStats(func() storage.ResultCacheStats {
+		return storage.ResultCacheStats{Hits: 3, Misses: 1, Bypasses: 2}
+	})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp HealthResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.ResultCache)
+	assert.Equal(t, storage.ResultCacheStats{Hits: 3, Misses: 1, Bypasses: 2}, *resp.ResultCache)
+}
+
+func TestHealthHandler_ReadMode(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		chPing PingFunc
+		want   string
+	}{
+		{"clickhouse healthy", okPing, "live"},
+		{"clickhouse down", failPing, "cached"},
+		{"clickhouse not configured", nil, "live"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHealthHandler(okPing, tc.chPing, okPing, okPing)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			var resp HealthResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tc.want, resp.ReadMode)
+		})
+	}
+}
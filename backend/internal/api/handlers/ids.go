@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+)
+
+// Identifiers are validated and canonicalised by middleware.CanonicalIDs.
+// The helpers below read them from the request context, and parse them the
+// same way when a handler is served without the middleware.
+
+// requestTenant returns the tenant of r, answering 401 when the request has
+// none and 400 when it is not a UUID.
+func requestTenant(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return uuid.Nil, false
+	}
+	tid, err := middleware.ParseID(tenantID)
+	if err != nil {
+		middleware.WriteIDError(w, "tenant_id", "auth", err)
+		return uuid.Nil, false
+	}
+	return tid, true
+}
+
+// pathID returns the UUID path parameter name of r, answering 400 naming the
+// parameter when it is malformed or the nil UUID.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	if id, ok := middleware.GetID(r.Context(), name); ok {
+		return id, true
+	}
+	id, err := middleware.ParseID(mux.Vars(r)[name])
+	if err != nil {
+		middleware.WriteIDError(w, name, "path", err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// queryID returns the UUID query parameter name of r, or uuid.Nil when it is
+// not set, answering 400 naming the parameter when it is malformed.
+func queryID(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	if id, ok := middleware.GetID(r.Context(), name); ok {
+		return id, true
+	}
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return uuid.Nil, true
+	}
+	id, err := middleware.ParseID(raw)
+	if err != nil {
+		middleware.WriteIDError(w, name, "query", err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// lookupID returns the UUID path or query parameter name of r, or false when
+// it is not set or not valid.
+func lookupID(r *http.Request, name string) (uuid.UUID, bool) {
+	if id, ok := middleware.GetID(r.Context(), name); ok {
+		return id, true
+	}
+	raw := mux.Vars(r)[name]
+	if raw == "" {
+		raw = r.URL.Query().Get(name)
+	}
+	id, err := middleware.ParseID(raw)
+	return id, err == nil
+}
+
+// bodyID parses the UUID field name of a request body, answering 400 naming
+// the field when it is malformed or the nil UUID.
+func bodyID(w http.ResponseWriter, name, raw string) (uuid.UUID, bool) {
+	id, err := middleware.ParseID(raw)
+	if err != nil {
+		middleware.WriteIDError(w, name, "body", err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// TestRouter_MixedCaseIDs serves a dashboard through the router, whose
+// Redis key is built from the tenant and job IDs as strings: the uppercase
+// and mixed-case forms of the IDs must find the same data as the lowercase
+// form instead of an empty cache.
+func TestRouter_MixedCaseIDs(t *testing.T) {
+	tenantID := uuid.MustParse("6f1c2b3a-4d5e-4f60-8a9b-0c1d2e3f4a5b")
+	jobID := uuid.MustParse("0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d")
+
+	pg := new(testutil.MockPostgresStore)
+	redis := new(testutil.MockRedisCache)
+	pg.On("GetJob", mock.Anything, tenantID, jobID).
+		Return(&domain.AnalysisJob{ID: jobID, TenantID: tenantID, Status: domain.JobStatusComplete}, nil)
+	redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return("dashboard-key")
+	redis.On("Get", mock.Anything, "dashboard-key").
+		Return(`{"general_stats":{"total_lines":42}}`, nil)
+
+	router := api.NewRouter(api.RouterConfig{
+		AllowedOrigins:      []string{"*"},
+		DevMode:             true,
+		GetDashboardHandler: NewDashboardHandler(pg, nil, redis),
+	})
+	get := func(tenant, job string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+job+"/dashboard", nil)
+		req.Header.Set("X-Dev-User-ID", "user-1")
+		req.Header.Set("X-Dev-Tenant-ID", tenant)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	lower := get(tenantID.String(), jobID.String())
+	require.Equal(t, http.StatusOK, lower.Code, lower.Body.String())
+	assert.Contains(t, lower.Body.String(), `"total_lines":42`)
+
+	for name, ids := range map[string][2]string{
+		"uppercase":  {strings.ToUpper(tenantID.String()), strings.ToUpper(jobID.String())},
+		"mixed case": {"6F1c2B3a-4D5e-4F60-8a9B-0c1D2e3F4a5B", "0B7d5A4e-3F0c-4C1e-9A8e-5D6f7A8b9C0d"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := get(ids[0], ids[1])
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.JSONEq(t, lower.Body.String(), w.Body.String())
+		})
+	}
+
+	t.Run("nil job ID", func(t *testing.T) {
+		w := get(tenantID.String(), uuid.Nil.String())
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"parameter":"job_id"`)
+	})
+	t.Run("braced tenant ID", func(t *testing.T) {
+		w := get("{"+tenantID.String()+"}", jobID.String())
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"parameter":"tenant_id"`)
+	})
+}
+
+func TestIDHelpers(t *testing.T) {
+	decodeDetails := func(t *testing.T, w *httptest.ResponseRecorder) middleware.IDError {
+		t.Helper()
+		var resp struct {
+			Code    string             `json:"code"`
+			Details middleware.IDError `json:"details"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, api.ErrCodeInvalidRequest, resp.Code)
+		return resp.Details
+	}
+
+	t.Run("path ID without the middleware", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := newTestRequest(http.MethodGet, "/").vars("job_id", strings.ToUpper(fixedFileID.String())).build()
+		id, ok := pathID(w, r, "job_id")
+		require.True(t, ok)
+		assert.Equal(t, fixedFileID, id)
+	})
+
+	t.Run("nil path ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := newTestRequest(http.MethodGet, "/").vars("job_id", uuid.Nil.String()).build()
+		_, ok := pathID(w, r, "job_id")
+		require.False(t, ok)
+		assert.Equal(t, middleware.IDError{Parameter: "job_id", Source: "path", Reason: middleware.ErrNilID.Error()}, decodeDetails(t, w))
+	})
+
+	t.Run("unset query ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		id, ok := queryID(w, httptest.NewRequest(http.MethodGet, "/", nil), "job_id")
+		assert.True(t, ok)
+		assert.Equal(t, uuid.Nil, id)
+	})
+
+	t.Run("malformed query ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, ok := queryID(w, httptest.NewRequest(http.MethodGet, "/?job_id=job-1", nil), "job_id")
+		require.False(t, ok)
+		assert.Equal(t, "query", decodeDetails(t, w).Source)
+	})
+
+	t.Run("body ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, ok := bodyID(w, "file_id", "urn:uuid:"+fixedFileID.String())
+		require.False(t, ok)
+		assert.Equal(t, middleware.IDError{Parameter: "file_id", Source: "body", Reason: middleware.ErrMalformedID.Error()}, decodeDetails(t, w))
+	})
+
+	t.Run("tenant", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, ok := requestTenant(w, newTestRequest(http.MethodGet, "/").tenant("tenant-1").build())
+		require.False(t, ok)
+		assert.Equal(t, "tenant_id", decodeDetails(t, w).Parameter)
+
+		w = httptest.NewRecorder()
+		_, ok = requestTenant(w, newTestRequest(http.MethodGet, "/").build())
+		require.False(t, ok)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// maxIncidentGroupJobs caps the analyses an incident group is created
+	// with.
+	maxIncidentGroupJobs = 20
+
+	// maxIncidentGroupName caps the length of a group name.
+	maxIncidentGroupName = 200
+
+	// maxOverlayEvents is the number of longest entries of each overlaid
+	// analysis drawn as events.
+	maxOverlayEvents = 100
+)
+
+// incidentGroupRequest is the body of POST /api/v1/incident-groups.
+type incidentGroupRequest struct {
+	Name   string   `json:"name"`
+	JobIDs []string `json:"job_ids"`
+}
+
+// jobIncidentGroupRequest is the body of PUT
+// /api/v1/analysis/{job_id}/incident-group. A null group takes the
+// analysis out of its group.
+type jobIncidentGroupRequest struct {
+	GroupID *string `json:"group_id"`
+}
+
+// IncidentGroupHandlers provides HTTP handlers for incident groups: the
+// analyses of one incident, such as the AR Server log and the server's
+// Tomcat access and JVM GC logs, whose timelines are overlaid.
+type IncidentGroupHandlers struct {
+	pg storage.PostgresStore
+}
+
+func NewIncidentGroupHandlers(pg storage.PostgresStore) *IncidentGroupHandlers {
+	return &IncidentGroupHandlers{pg: pg}
+}
+
+// CreateGroup handles POST /api/v1/incident-groups. Analyses already in a
+// group move to the new one.
+func (h *IncidentGroupHandlers) CreateGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		var req incidentGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" || len(name) > maxIncidentGroupName {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+				fmt.Sprintf("name is required and must be at most %d characters", maxIncidentGroupName))
+			return
+		}
+		if len(req.JobIDs) == 0 || len(req.JobIDs) > maxIncidentGroupJobs {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+				fmt.Sprintf("job_ids must list between 1 and %d analyses", maxIncidentGroupJobs))
+			return
+		}
+		jobIDs := make([]uuid.UUID, 0, len(req.JobIDs))
+		seen := make(map[uuid.UUID]bool, len(req.JobIDs))
+		for _, s := range req.JobIDs {
+			id, ok := bodyID(w, "job_ids", s)
+			if !ok {
+				return
+			}
+			if !seen[id] {
+				seen[id] = true
+				jobIDs = append(jobIDs, id)
+			}
+		}
+
+		group := &domain.IncidentGroup{
+			TenantID:  tid,
+			Name:      name,
+			CreatedBy: middleware.GetUserID(r.Context()),
+		}
+		if err := h.pg.CreateIncidentGroup(r.Context(), group, jobIDs); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+				return
+			}
+			slog.Error("failed to create incident group", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create incident group")
+			return
+		}
+
+		created, err := h.pg.GetIncidentGroup(storage.WithPrimary(r.Context()), tid, group.ID)
+		if err != nil {
+			slog.Error("failed to retrieve incident group", "group_id", group.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve incident group")
+			return
+		}
+		api.JSON(w, http.StatusCreated, created)
+	})
+}
+
+// GetGroup handles GET /api/v1/incident-groups/{group_id}.
+func (h *IncidentGroupHandlers) GetGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		groupID, ok := pathID(w, r, "group_id")
+		if !ok {
+			return
+		}
+
+		group, err := h.pg.GetIncidentGroup(r.Context(), tid, groupID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "incident group not found")
+				return
+			}
+			slog.Error("failed to retrieve incident group", "group_id", groupID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve incident group")
+			return
+		}
+		api.JSON(w, http.StatusOK, group)
+	})
+}
+
+// SetJobGroup handles PUT /api/v1/analysis/{job_id}/incident-group.
+func (h *IncidentGroupHandlers) SetJobGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		var req jobIncidentGroupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		var groupID *uuid.UUID
+		if req.GroupID != nil {
+			id, ok := bodyID(w, "group_id", *req.GroupID)
+			if !ok {
+				return
+			}
+			groupID = &id
+		}
+
+		if err := h.pg.SetJobIncidentGroup(r.Context(), tid, jobID, groupID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job or incident group not found")
+				return
+			}
+			slog.Error("failed to set incident group", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to set incident group")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// includeGroup reports whether a section request asks for the overlays of
+// the job's incident group with ?include_group=true.
+func includeGroup(r *http.Request) bool {
+	return r.URL.Query().Get("include_group") == "true"
+}
+
+// groupOverlays returns the overlays of the complete non-AR analyses of
+// job's incident group, none when the job is in no group.
+func groupOverlays(ctx context.Context, pg storage.PostgresStore, ch storage.ClickHouseStore, job *domain.AnalysisJob) ([]domain.SourceOverlay, error) {
+	if job.IncidentGroupID == nil {
+		return []domain.SourceOverlay{}, nil
+	}
+	group, err := pg.GetIncidentGroup(ctx, job.TenantID, *job.IncidentGroupID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return []domain.SourceOverlay{}, nil
+		}
+		return nil, err
+	}
+
+	overlays := []domain.SourceOverlay{}
+	for _, m := range group.Members {
+		if m.JobID == job.ID || m.Status != domain.JobStatusComplete ||
+			m.SourceType == "" || m.SourceType == domain.LogSourceARServer {
+			continue
+		}
+		overlay, err := ch.GetSourceOverlay(ctx, job.TenantID.String(), m.JobID.String(), maxOverlayEvents)
+		if err != nil {
+			return nil, err
+		}
+		overlay.JobID, overlay.SourceType = m.JobID, m.SourceType
+		overlays = append(overlays, *overlay)
+	}
+	return overlays, nil
+}
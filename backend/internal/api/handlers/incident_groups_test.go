@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var (
+	fixedGroupID = uuid.MustParse("00000000-0000-0000-0000-000000000021")
+	fixedGCJobID = uuid.MustParse("00000000-0000-0000-0000-000000000022")
+)
+
+// sampleIncidentGroup is a group of the AR analysis fixedJobID with a
+// complete GC analysis and a Tomcat analysis still running.
+func sampleIncidentGroup() *domain.IncidentGroup {
+	return &domain.IncidentGroup{
+		ID: fixedGroupID, TenantID: fixedTenantID, Name: "INC0012345",
+		Members: []domain.IncidentGroupMember{
+			{JobID: fixedJobID, Filename: "arserver.log", SourceType: domain.LogSourceARServer, Status: domain.JobStatusComplete},
+			{JobID: fixedGCJobID, Filename: "gc.log", SourceType: domain.LogSourceJVMGC, Status: domain.JobStatusComplete},
+			{JobID: uuid.New(), Filename: "localhost_access_log.txt", SourceType: domain.LogSourceTomcatAccess, Status: domain.JobStatusParsing},
+		},
+	}
+}
+
+func sampleGCOverlay() *domain.SourceOverlay {
+	ts := domain.NewTimestamp(time.Date(2025, 10, 10, 13, 55, 0, 0, time.UTC))
+	return &domain.SourceOverlay{
+		Series: []domain.OverlayPoint{{Timestamp: ts, LogType: domain.LogTypeGC, Count: 2, MaxDurationMS: 4235}},
+		Events: []domain.OverlayEvent{{EntryID: "e1", Timestamp: ts, LogType: domain.LogTypeGC, DurationMS: 4235, Label: "Pause Full (G1 Compaction Pause)", Success: true}},
+	}
+}
+
+func TestIncidentGroupHandlers_CreateGroup(t *testing.T) {
+	m := newHandlerMocks()
+	m.pg.On("CreateIncidentGroup", mock.Anything, mock.MatchedBy(func(g *domain.IncidentGroup) bool {
+		return g.Name == "INC0012345" && g.TenantID == fixedTenantID && g.CreatedBy == "user-1"
+	}), []uuid.UUID{fixedJobID, fixedGCJobID}).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.IncidentGroup).ID = fixedGroupID
+	})
+	m.pg.On("GetIncidentGroup", mock.Anything, fixedTenantID, fixedGroupID).Return(sampleIncidentGroup(), nil)
+
+	w := newTestRequest(http.MethodPost, "/api/v1/incident-groups").
+		tenant(fixedTenantID.String()).
+		user("user-1").
+		jsonBody(t, map[string]any{
+			"name":    " INC0012345 ",
+			"job_ids": []string{fixedJobID.String(), fixedGCJobID.String(), fixedJobID.String()},
+		}).
+		serve(NewIncidentGroupHandlers(m.pg).CreateGroup())
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	m.assertExpectations(t)
+	var got domain.IncidentGroup
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, fixedGroupID, got.ID)
+	assert.Len(t, got.Members, 3)
+}
+
+func TestIncidentGroupHandlers_CreateGroupErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       map[string]any
+		storeErr   error
+		wantStatus int
+	}{
+		{"missing name", map[string]any{"job_ids": []string{fixedJobID.String()}}, nil, http.StatusBadRequest},
+		{"no jobs", map[string]any{"name": "INC1"}, nil, http.StatusBadRequest},
+		{"invalid job", map[string]any{"name": "INC1", "job_ids": []string{"nope"}}, nil, http.StatusBadRequest},
+		{"job of another tenant", map[string]any{"name": "INC1", "job_ids": []string{fixedJobID.String()}},
+			errors.New("postgres: job not found in incident group x"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			if tt.storeErr != nil {
+				m.pg.On("CreateIncidentGroup", mock.Anything, mock.Anything, mock.Anything).Return(tt.storeErr)
+			}
+
+			w := newTestRequest(http.MethodPost, "/api/v1/incident-groups").
+				tenant(fixedTenantID.String()).
+				jsonBody(t, tt.body).
+				serve(NewIncidentGroupHandlers(m.pg).CreateGroup())
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			m.assertExpectations(t)
+		})
+	}
+}
+
+func TestIncidentGroupHandlers_GetGroup(t *testing.T) {
+	m := newHandlerMocks()
+	m.pg.On("GetIncidentGroup", mock.Anything, fixedTenantID, fixedGroupID).Return(sampleIncidentGroup(), nil)
+	w := newTestRequest(http.MethodGet, "/api/v1/incident-groups/"+fixedGroupID.String()).
+		tenant(fixedTenantID.String()).
+		vars("group_id", fixedGroupID.String()).
+		serve(NewIncidentGroupHandlers(m.pg).GetGroup())
+	require.Equal(t, http.StatusOK, w.Code)
+
+	m = newHandlerMocks()
+	m.pg.On("GetIncidentGroup", mock.Anything, fixedTenantID, fixedGroupID).
+		Return(nil, errors.New("postgres: incident group not found: x"))
+	w = newTestRequest(http.MethodGet, "/api/v1/incident-groups/"+fixedGroupID.String()).
+		tenant(fixedTenantID.String()).
+		vars("group_id", fixedGroupID.String()).
+		serve(NewIncidentGroupHandlers(m.pg).GetGroup())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestIncidentGroupHandlers_SetJobGroup(t *testing.T) {
+	m := newHandlerMocks()
+	m.pg.On("SetJobIncidentGroup", mock.Anything, fixedTenantID, fixedJobID, &fixedGroupID).Return(nil)
+	m.pg.On("SetJobIncidentGroup", mock.Anything, fixedTenantID, fixedJobID, (*uuid.UUID)(nil)).Return(nil)
+	h := NewIncidentGroupHandlers(m.pg).SetJobGroup()
+
+	for _, body := range []map[string]any{{"group_id": fixedGroupID.String()}, {"group_id": nil}} {
+		w := newTestRequest(http.MethodPut, "/api/v1/analysis/"+fixedJobID.String()+"/incident-group").
+			tenant(fixedTenantID.String()).
+			vars("job_id", fixedJobID.String()).
+			jsonBody(t, body).
+			serve(h)
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	}
+	m.assertExpectations(t)
+}
+
+func TestDashboardHandler_IncludeGroup(t *testing.T) {
+	m := newHandlerMocks()
+	job := completedJob(fixedTenantID, fixedJobID)
+	job.IncidentGroupID = &fixedGroupID
+	m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+	m.pg.On("GetIncidentGroup", mock.Anything, fixedTenantID, fixedGroupID).Return(sampleIncidentGroup(), nil)
+	m.ch.On("GetSourceOverlay", mock.Anything, fixedTenantID.String(), fixedGCJobID.String(), maxOverlayEvents).
+		Return(sampleGCOverlay(), nil).Once()
+	cacheKey := "tenant:" + fixedTenantID.String() + ":dashboard:" + fixedJobID.String()
+	m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(cacheKey)
+	cachedJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+	m.redis.On("Get", mock.Anything, cacheKey).Return(string(cachedJSON), nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/dashboard?include_group=true").
+		tenant(fixedTenantID.String()).
+		vars("job_id", fixedJobID.String()).
+		header("If-None-Match", sectionETag(job, "dashboard")).
+		serve(NewDashboardHandler(m.pg, m.ch, m.redis))
+
+	require.Equal(t, http.StatusOK, w.Code, "overlays are not covered by the job's ETag")
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	m.assertExpectations(t)
+	var got domain.DashboardData
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Len(t, got.Overlays, 1, "the AR job itself and running analyses are left out")
+	assert.Equal(t, fixedGCJobID, got.Overlays[0].JobID)
+	assert.Equal(t, domain.LogSourceJVMGC, got.Overlays[0].SourceType)
+	assert.Equal(t, int64(4235), got.Overlays[0].Events[0].DurationMS)
+}
+
+func TestGapsHandler_IncludeGroup(t *testing.T) {
+	m := newHandlerMocks()
+	job := completedJob(fixedTenantID, fixedJobID)
+	job.IncidentGroupID = &fixedGroupID
+	m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+	m.pg.On("GetIncidentGroup", mock.Anything, fixedTenantID, fixedGroupID).Return(sampleIncidentGroup(), nil)
+	m.ch.On("GetSourceOverlay", mock.Anything, fixedTenantID.String(), fixedGCJobID.String(), maxOverlayEvents).
+		Return(sampleGCOverlay(), nil)
+	baseKey := "tenant:" + fixedTenantID.String() + ":dashboard:" + fixedJobID.String()
+	m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+	cachedJSON, err := json.Marshal(domain.GapsResponse{Gaps: []domain.GapEntry{{DurationMS: 5000}}})
+	require.NoError(t, err)
+	m.redis.On("Get", mock.Anything, baseKey+":gaps").Return(string(cachedJSON), nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/dashboard/gaps?include_group=true").
+		tenant(fixedTenantID.String()).
+		vars("job_id", fixedJobID.String()).
+		serve(NewGapsHandler(m.pg, m.ch, m.redis))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	m.assertExpectations(t)
+	var got domain.GapsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Len(t, got.Overlays, 1)
+	assert.Equal(t, domain.LogTypeGC, got.Overlays[0].Series[0].LogType)
+
+	// Without the parameter, the group is not read.
+	m = newHandlerMocks()
+	m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+	m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+	m.redis.On("Get", mock.Anything, baseKey+":gaps").Return(string(cachedJSON), nil)
+	w = newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/dashboard/gaps").
+		tenant(fixedTenantID.String()).
+		vars("job_id", fixedJobID.String()).
+		serve(NewGapsHandler(m.pg, m.ch, m.redis))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "overlays")
+	m.assertExpectations(t)
+}
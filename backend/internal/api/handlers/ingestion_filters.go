@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// maxIngestionFilterRulesPerTenant caps the number of rules every ingested
+// entry is matched against.
+const maxIngestionFilterRulesPerTenant = 50
+
+// ingestionFilterRuleRequest is the body of rule create and update
+// requests. Enabled defaults to true and Action to drop.
+type ingestionFilterRuleRequest struct {
+	Name          string                         `json:"name"`
+	Field         string                         `json:"field"`
+	Operator      domain.IngestionFilterOperator `json:"operator"`
+	Value         string                         `json:"value"`
+	CaseSensitive bool                           `json:"case_sensitive"`
+	Action        domain.IngestionFilterAction   `json:"action"`
+	Enabled       *bool                          `json:"enabled"`
+}
+
+// apply copies the request onto rule and validates the result.
+func (req ingestionFilterRuleRequest) apply(rule *domain.IngestionFilterRule) error {
+	rule.Name = req.Name
+	rule.Field = req.Field
+	rule.Operator = req.Operator
+	rule.Value = req.Value
+	rule.CaseSensitive = req.CaseSensitive
+	rule.Action = req.Action
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	return worker.ValidateIngestionFilterRule(rule)
+}
+
+// ingestionFilterDryRunRequest is the body of a dry run. Without rules the
+// tenant's stored rules are counted, disabled ones included.
+type ingestionFilterDryRunRequest struct {
+	JobID string                       `json:"job_id"`
+	Rules []ingestionFilterRuleRequest `json:"rules"`
+}
+
+// ingestionFilterDryRunResult is how many entries of the job one rule
+// matches.
+type ingestionFilterDryRunResult struct {
+	Rule    domain.IngestionFilterRule `json:"rule"`
+	Matched int64                      `json:"matched"`
+}
+
+// IngestionFilterHandlers provides HTTP handlers for tenant-defined
+// ingestion filter rules.
+type IngestionFilterHandlers struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+// NewIngestionFilterHandlers creates the ingestion filter rule handlers.
+func NewIngestionFilterHandlers(pg storage.PostgresStore, ch storage.ClickHouseStore) *IngestionFilterHandlers {
+	return &IngestionFilterHandlers{pg: pg, ch: ch}
+}
+
+// ListRules handles GET /api/v1/ingestion-filters.
+func (h *IngestionFilterHandlers) ListRules() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		rules, err := h.pg.ListIngestionFilterRules(r.Context(), tid)
+		if err != nil {
+			slog.Error("failed to list ingestion filter rules", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list ingestion filter rules")
+			return
+		}
+		if rules == nil {
+			rules = []domain.IngestionFilterRule{}
+		}
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"rules": rules,
+		})
+	})
+}
+
+// CreateRule handles POST /api/v1/ingestion-filters.
+func (h *IngestionFilterHandlers) CreateRule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		var req ingestionFilterRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		rule := &domain.IngestionFilterRule{TenantID: tid}
+		if err := req.apply(rule); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		existing, err := h.pg.ListIngestionFilterRules(r.Context(), tid)
+		if err == nil && len(existing) >= maxIngestionFilterRulesPerTenant {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "maximum ingestion filter rules limit reached (50)")
+			return
+		}
+
+		if err := h.pg.CreateIngestionFilterRule(r.Context(), rule); err != nil {
+			slog.Error("failed to create ingestion filter rule", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create ingestion filter rule")
+			return
+		}
+
+		api.JSON(w, http.StatusCreated, rule)
+	})
+}
+
+// UpdateRule handles PUT /api/v1/ingestion-filters/{rule_id}.
+func (h *IngestionFilterHandlers) UpdateRule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		ruleID, ok := pathID(w, r, "rule_id")
+		if !ok {
+			return
+		}
+
+		var req ingestionFilterRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+
+		rule, err := h.pg.GetIngestionFilterRule(r.Context(), tid, ruleID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "ingestion filter rule not found")
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve ingestion filter rule")
+			}
+			return
+		}
+		if err := req.apply(rule); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		if err := h.pg.UpdateIngestionFilterRule(r.Context(), rule); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "ingestion filter rule not found")
+				return
+			}
+			slog.Error("failed to update ingestion filter rule", "rule_id", ruleID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to update ingestion filter rule")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, rule)
+	})
+}
+
+// DeleteRule handles DELETE /api/v1/ingestion-filters/{rule_id}.
+func (h *IngestionFilterHandlers) DeleteRule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		ruleID, ok := pathID(w, r, "rule_id")
+		if !ok {
+			return
+		}
+
+		if err := h.pg.DeleteIngestionFilterRule(r.Context(), tid, ruleID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "ingestion filter rule not found")
+				return
+			}
+			slog.Error("failed to delete ingestion filter rule", "rule_id", ruleID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to delete ingestion filter rule")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// DryRun handles POST /api/v1/ingestion-filters/dry-run, reporting how many
+// entries of a completed job each rule would have matched. Entries dropped
+// when the job was ingested are not counted.
+func (h *IngestionFilterHandlers) DryRun() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		var req ingestionFilterDryRunRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		jobID, ok := bodyID(w, "job_id", req.JobID)
+		if !ok {
+			return
+		}
+		if len(req.Rules) > maxIngestionFilterRulesPerTenant {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "at most 50 rules can be tried at once")
+			return
+		}
+		rules := make([]domain.IngestionFilterRule, len(req.Rules))
+		for i, rr := range req.Rules {
+			rules[i].TenantID = tid
+			if err := rr.apply(&rules[i]); err != nil {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+				return
+			}
+		}
+
+		job, err := h.pg.GetJob(r.Context(), tid, jobID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+		if job.Status != domain.JobStatusComplete {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+			return
+		}
+
+		if len(req.Rules) == 0 {
+			if rules, err = h.pg.ListIngestionFilterRules(r.Context(), tid); err != nil {
+				slog.Error("failed to list ingestion filter rules", "tenant_id", tid, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list ingestion filter rules")
+				return
+			}
+		}
+
+		tenantID, id := tid.String(), jobID.String()
+		total, err := h.ch.CountJobEntries(r.Context(), tenantID, id)
+		if err != nil {
+			slog.Error("ingestion filter dry run: count failed", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to count job entries")
+			return
+		}
+		results := make([]ingestionFilterDryRunResult, 0, len(rules))
+		for _, rule := range rules {
+			n, err := h.ch.CountIngestionFilterMatches(r.Context(), tenantID, id, rule)
+			if err != nil {
+				slog.Error("ingestion filter dry run: count failed", "job_id", jobID, "rule", rule.Name, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to count matching entries")
+				return
+			}
+			results = append(results, ingestionFilterDryRunResult{Rule: rule, Matched: n})
+		}
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"job_id":        jobID,
+			"total_entries": total,
+			"results":       results,
+		})
+	})
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestIngestionFilterHandlers_CreateRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       map[string]any
+		setupMocks func(m *handlerMocks)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "creates rule with defaults",
+			body: map[string]any{"name": "Health probe", "field": "user", "operator": "equals", "value": "HealthCheckUser"},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("ListIngestionFilterRules", mock.Anything, fixedTenantID).Return([]domain.IngestionFilterRule{}, nil)
+				m.pg.On("CreateIngestionFilterRule", mock.Anything, mock.MatchedBy(func(r *domain.IngestionFilterRule) bool {
+					return r.TenantID == fixedTenantID && r.Enabled && !r.CaseSensitive && r.Action == domain.IngestionFilterDrop
+				})).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.IngestionFilterRule
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, "user", resp.Field)
+				assert.Equal(t, domain.IngestionFilterDrop, resp.Action)
+			},
+		},
+		{
+			name:       "field outside the whitelist returns 400",
+			body:       map[string]any{"name": "raw", "field": "raw_text", "operator": "contains", "value": "probe"},
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, `unknown field "raw_text"`)
+			},
+		},
+		{
+			name:       "bad action returns 400",
+			body:       map[string]any{"name": "monitor", "field": "form", "operator": "prefix", "value": "AR System Monitor", "action": "hide"},
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "limit reached returns 409",
+			body: map[string]any{"name": "monitor", "field": "form", "operator": "prefix", "value": "AR System Monitor", "action": "flag"},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("ListIngestionFilterRules", mock.Anything, fixedTenantID).
+					Return(make([]domain.IngestionFilterRule, maxIngestionFilterRulesPerTenant), nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			tc.setupMocks(m)
+
+			w := newTestRequest(http.MethodPost, "/api/v1/ingestion-filters").
+				tenant(fixedTenantID.String()).
+				jsonBody(t, tc.body).
+				serve(NewIngestionFilterHandlers(m.pg, m.ch).CreateRule())
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			m.assertExpectations(t)
+		})
+	}
+}
+
+func TestIngestionFilterHandlers_ListRules(t *testing.T) {
+	m := newHandlerMocks()
+	m.pg.On("ListIngestionFilterRules", mock.Anything, fixedTenantID).Return(nil, nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/ingestion-filters").
+		tenant(fixedTenantID.String()).
+		serve(NewIngestionFilterHandlers(m.pg, m.ch).ListRules())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rules": []}`, w.Body.String())
+
+	w = newTestRequest(http.MethodGet, "/api/v1/ingestion-filters").
+		serve(NewIngestionFilterHandlers(m.pg, m.ch).ListRules())
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestIngestionFilterHandlers_UpdateAndDeleteRule(t *testing.T) {
+	body := map[string]any{"name": "Health probe", "field": "user", "operator": "equals", "value": "HealthCheckUser", "case_sensitive": true, "enabled": false}
+
+	t.Run("updates rule", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetIngestionFilterRule", mock.Anything, fixedTenantID, fixedRuleID).
+			Return(&domain.IngestionFilterRule{ID: fixedRuleID, TenantID: fixedTenantID, Enabled: true}, nil)
+		m.pg.On("UpdateIngestionFilterRule", mock.Anything, mock.MatchedBy(func(r *domain.IngestionFilterRule) bool {
+			return r.ID == fixedRuleID && r.CaseSensitive && !r.Enabled
+		})).Return(nil)
+
+		w := newTestRequest(http.MethodPut, "/api/v1/ingestion-filters/"+fixedRuleID.String()).
+			tenant(fixedTenantID.String()).
+			vars("rule_id", fixedRuleID.String()).
+			jsonBody(t, body).
+			serve(NewIngestionFilterHandlers(m.pg, m.ch).UpdateRule())
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		m.assertExpectations(t)
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetIngestionFilterRule", mock.Anything, fixedTenantID, fixedRuleID).
+			Return(nil, fmt.Errorf("postgres: ingestion filter rule not found: %s", fixedRuleID))
+
+		w := newTestRequest(http.MethodPut, "/api/v1/ingestion-filters/"+fixedRuleID.String()).
+			tenant(fixedTenantID.String()).
+			vars("rule_id", fixedRuleID.String()).
+			jsonBody(t, body).
+			serve(NewIngestionFilterHandlers(m.pg, m.ch).UpdateRule())
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("deletes rule", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("DeleteIngestionFilterRule", mock.Anything, fixedTenantID, fixedRuleID).Return(nil)
+
+		w := newTestRequest(http.MethodDelete, "/api/v1/ingestion-filters/"+fixedRuleID.String()).
+			tenant(fixedTenantID.String()).
+			vars("rule_id", fixedRuleID.String()).
+			serve(NewIngestionFilterHandlers(m.pg, m.ch).DeleteRule())
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		m.assertExpectations(t)
+	})
+}
+
+func TestIngestionFilterHandlers_DryRun(t *testing.T) {
+	stored := domain.IngestionFilterRule{ID: fixedRuleID, TenantID: fixedTenantID, Name: "Health probe", Field: "user",
+		Operator: domain.IngestionFilterEquals, Value: "HealthCheckUser", Action: domain.IngestionFilterDrop}
+
+	tests := []struct {
+		name       string
+		body       map[string]any
+		setupMocks func(m *handlerMocks)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "counts the stored rules",
+			body: map[string]any{"job_id": fixedJobID.String()},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+				m.pg.On("ListIngestionFilterRules", mock.Anything, fixedTenantID).Return([]domain.IngestionFilterRule{stored}, nil)
+				m.ch.On("CountJobEntries", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(int64(1000), nil)
+				m.ch.On("CountIngestionFilterMatches", mock.Anything, fixedTenantID.String(), fixedJobID.String(), stored).Return(int64(400), nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp struct {
+					TotalEntries int64                         `json:"total_entries"`
+					Results      []ingestionFilterDryRunResult `json:"results"`
+				}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, int64(1000), resp.TotalEntries)
+				require.Len(t, resp.Results, 1)
+				assert.Equal(t, "Health probe", resp.Results[0].Rule.Name)
+				assert.Equal(t, int64(400), resp.Results[0].Matched)
+			},
+		},
+		{
+			name: "counts candidate rules",
+			body: map[string]any{"job_id": fixedJobID.String(), "rules": []map[string]any{
+				{"name": "monitor", "field": "form", "operator": "prefix", "value": "AR System Monitor", "action": "flag"},
+			}},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+				m.ch.On("CountJobEntries", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(int64(1000), nil)
+				m.ch.On("CountIngestionFilterMatches", mock.Anything, fixedTenantID.String(), fixedJobID.String(), mock.MatchedBy(func(r domain.IngestionFilterRule) bool {
+					return r.Field == "form" && r.Operator == domain.IngestionFilterPrefix && r.Action == domain.IngestionFilterFlag
+				})).Return(int64(12), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "invalid candidate rule returns 400",
+			body: map[string]any{"job_id": fixedJobID.String(), "rules": []map[string]any{
+				{"name": "slow", "field": "duration_ms", "operator": "equals", "value": "0"},
+			}},
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid job_id returns 400",
+			body:       map[string]any{"job_id": "nope"},
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "job not complete returns 409",
+			body: map[string]any{"job_id": fixedJobID.String()},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsingJob(fixedTenantID, fixedJobID), nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "count failure returns 500",
+			body: map[string]any{"job_id": fixedJobID.String()},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+				m.pg.On("ListIngestionFilterRules", mock.Anything, fixedTenantID).Return([]domain.IngestionFilterRule{stored}, nil)
+				m.ch.On("CountJobEntries", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(int64(1000), nil)
+				m.ch.On("CountIngestionFilterMatches", mock.Anything, fixedTenantID.String(), fixedJobID.String(), stored).Return(int64(0), errors.New("clickhouse down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			tc.setupMocks(m)
+
+			w := newTestRequest(http.MethodPost, "/api/v1/ingestion-filters/dry-run").
+				tenant(fixedTenantID.String()).
+				jsonBody(t, tc.body).
+				serve(NewIngestionFilterHandlers(m.pg, m.ch).DryRun())
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			m.assertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// maxInvestigationNotesBytes caps the markdown findings of one analysis.
+	maxInvestigationNotesBytes = 64 * 1024
+
+	// maxAssigneeLength caps assignee user IDs.
+	maxAssigneeLength = 255
+)
+
+// investigationTransitions lists the statuses each investigation status may
+// move to. Keeping the current status is always allowed so that notes and
+// assignee can be edited on their own.
+var investigationTransitions = map[domain.InvestigationStatus][]domain.InvestigationStatus{
+	domain.InvestigationNew: {
+		domain.InvestigationInvestigating,
+		domain.InvestigationArchived,
+	},
+	domain.InvestigationInvestigating: {
+		domain.InvestigationRootCauseIdentified,
+		domain.InvestigationResolved,
+		domain.InvestigationArchived,
+	},
+	domain.InvestigationRootCauseIdentified: {
+		domain.InvestigationInvestigating,
+		domain.InvestigationResolved,
+		domain.InvestigationArchived,
+	},
+	domain.InvestigationResolved: {
+		domain.InvestigationInvestigating,
+		domain.InvestigationArchived,
+	},
+	domain.InvestigationArchived: {
+		domain.InvestigationInvestigating,
+	},
+}
+
+// validInvestigationStatus reports whether s is a known status.
+func validInvestigationStatus(s domain.InvestigationStatus) bool {
+	_, ok := investigationTransitions[s]
+	return ok
+}
+
+// canTransitionInvestigation reports whether an investigation may move from
+// one status to another.
+func canTransitionInvestigation(from, to domain.InvestigationStatus) bool {
+	if from == to {
+		return validInvestigationStatus(from)
+	}
+	for _, next := range investigationTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// InvestigationBroadcaster pushes investigation changes to a tenant's open
+// dashboards. *streaming.Hub implements it.
+type InvestigationBroadcaster interface {
+	BroadcastInvestigation(tenantID string, event domain.InvestigationEvent)
+}
+
+// investigationUpdateRequest is the body of PATCH .../investigation. Version
+// is the investigation version the client last read; absent fields are left
+// unchanged and an empty assignee clears the assignment.
+type investigationUpdateRequest struct {
+	Version  *int                        `json:"version"`
+	Status   *domain.InvestigationStatus `json:"status"`
+	Notes    *string                     `json:"notes"`
+	Assignee *string                     `json:"assignee"`
+}
+
+// investigationResponse is returned by a successful investigation update.
+type investigationResponse struct {
+	JobID         string               `json:"job_id"`
+	Investigation domain.Investigation `json:"investigation"`
+}
+
+// InvestigationHandlers provides HTTP handlers for the investigation
+// workflow of an analysis.
+type InvestigationHandlers struct {
+	pg     storage.PostgresStore
+	events InvestigationBroadcaster
+}
+
+// NewInvestigationHandlers creates the investigation handlers. events may be
+// nil, in which case updates are not broadcast.
+func NewInvestigationHandlers(pg storage.PostgresStore, events InvestigationBroadcaster) *InvestigationHandlers {
+	return &InvestigationHandlers{pg: pg, events: events}
+}
+
+// jobIDs extracts the tenant and job IDs, writing the error response when
+// either is missing or malformed.
+func (h *InvestigationHandlers) jobIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return uuid.Nil, uuid.Nil, false
+	}
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tid, jobID, true
+}
+
+// UpdateInvestigation handles PATCH /api/v1/analysis/{job_id}/investigation.
+// The update only applies if the stored version still equals the request's
+// version; otherwise it fails with 409 and the current investigation.
+func (h *InvestigationHandlers) UpdateInvestigation() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, jobID, ok := h.jobIDs(w, r)
+		if !ok {
+			return
+		}
+
+		var req investigationUpdateRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxInvestigationNotesBytes)).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		if req.Version == nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "version is required")
+			return
+		}
+		if req.Status != nil && !validInvestigationStatus(*req.Status) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid investigation status")
+			return
+		}
+		if req.Notes != nil && len(*req.Notes) > maxInvestigationNotesBytes {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "notes are too long")
+			return
+		}
+		if req.Assignee != nil {
+			assignee := strings.TrimSpace(*req.Assignee)
+			if len(assignee) > maxAssigneeLength {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "assignee is too long")
+				return
+			}
+			req.Assignee = &assignee
+		}
+
+		job, err := h.pg.GetJob(r.Context(), tid, jobID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				slog.Error("failed to retrieve job", "job_id", jobID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+
+		// The transition is checked against the version the client read;
+		// a newer version means the status may have moved since.
+		if job.Investigation.Version != *req.Version {
+			investigationConflict(w, job.Investigation)
+			return
+		}
+		if req.Status != nil && !canTransitionInvestigation(job.Investigation.Status, *req.Status) {
+			api.ErrorWithDetails(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest,
+				"investigation status transition not allowed", map[string]any{
+					"from":    job.Investigation.Status,
+					"to":      *req.Status,
+					"allowed": investigationTransitions[job.Investigation.Status],
+				})
+			return
+		}
+
+		update := domain.InvestigationUpdate{Status: req.Status, Notes: req.Notes, Assignee: req.Assignee}
+		inv, event, err := h.pg.UpdateJobInvestigation(r.Context(), tid, jobID, *req.Version, update, middleware.GetUserID(r.Context()))
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrVersionConflict):
+				investigationConflict(w, job.Investigation)
+			case storage.IsNotFound(err):
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			default:
+				slog.Error("failed to update investigation", "job_id", jobID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to update investigation")
+			}
+			return
+		}
+
+		if h.events != nil {
+			h.events.BroadcastInvestigation(tid.String(), *event)
+		}
+		api.JSON(w, http.StatusOK, investigationResponse{JobID: jobID.String(), Investigation: *inv})
+	})
+}
+
+// investigationConflict writes the 409 response of a stale update.
+func investigationConflict(w http.ResponseWriter, current domain.Investigation) {
+	api.ErrorWithDetails(w, http.StatusConflict, api.ErrCodeConflict,
+		"investigation was changed by someone else; reload and retry", map[string]any{
+			"current_version": current.Version,
+		})
+}
+
+// ListHistory handles GET /api/v1/analysis/{job_id}/investigation/history.
+func (h *InvestigationHandlers) ListHistory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, jobID, ok := h.jobIDs(w, r)
+		if !ok {
+			return
+		}
+
+		if _, err := h.pg.GetJob(r.Context(), tid, jobID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				slog.Error("failed to retrieve job", "job_id", jobID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+
+		events, err := h.pg.ListInvestigationEvents(r.Context(), tid, jobID)
+		if err != nil {
+			slog.Error("failed to list investigation history", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list investigation history")
+			return
+		}
+		api.JSON(w, http.StatusOK, map[string]any{
+			"job_id": jobID.String(),
+			"events": events,
+		})
+	})
+}
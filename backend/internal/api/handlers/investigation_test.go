@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// recordingBroadcaster records broadcast investigation events.
+type recordingBroadcaster struct {
+	tenants []string
+	events  []domain.InvestigationEvent
+}
+
+func (b *recordingBroadcaster) BroadcastInvestigation(tenantID string, event domain.InvestigationEvent) {
+	b.tenants = append(b.tenants, tenantID)
+	b.events = append(b.events, event)
+}
+
+func jobWithInvestigation(status domain.InvestigationStatus, version int) *domain.AnalysisJob {
+	job := completedJob(fixedTenantID, fixedJobID)
+	job.Investigation = domain.Investigation{Status: status, Version: version}
+	return job
+}
+
+func TestCanTransitionInvestigation(t *testing.T) {
+	const (
+		n  = domain.InvestigationNew
+		i  = domain.InvestigationInvestigating
+		rc = domain.InvestigationRootCauseIdentified
+		rs = domain.InvestigationResolved
+		a  = domain.InvestigationArchived
+	)
+	all := []domain.InvestigationStatus{n, i, rc, rs, a}
+	allowed := map[domain.InvestigationStatus]map[domain.InvestigationStatus]bool{
+		n:  {n: true, i: true, a: true},
+		i:  {i: true, rc: true, rs: true, a: true},
+		rc: {rc: true, i: true, rs: true, a: true},
+		rs: {rs: true, i: true, a: true},
+		a:  {a: true, i: true},
+	}
+
+	for _, from := range all {
+		for _, to := range all {
+			t.Run(fmt.Sprintf("%s to %s", from, to), func(t *testing.T) {
+				assert.Equal(t, allowed[from][to], canTransitionInvestigation(from, to))
+			})
+		}
+	}
+	assert.False(t, canTransitionInvestigation("bogus", "bogus"))
+	assert.False(t, canTransitionInvestigation(n, "bogus"))
+}
+
+func TestInvestigationHandlers_UpdateInvestigation(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name          string
+		jobID         string
+		body          string
+		setupMocks    func(pg *testutil.MockPostgresStore)
+		wantStatus    int
+		wantBroadcast bool
+		checkBody     func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "moves to investigating and broadcasts",
+			jobID: fixedJobID.String(),
+			body:  `{"version":0,"status":"investigating","notes":"## Findings","assignee":" user_2 "}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(jobWithInvestigation(domain.InvestigationNew, 0), nil)
+				pg.On("UpdateJobInvestigation", mock.Anything, fixedTenantID, fixedJobID, 0,
+					mock.MatchedBy(func(u domain.InvestigationUpdate) bool {
+						return *u.Status == domain.InvestigationInvestigating &&
+							*u.Notes == "## Findings" && *u.Assignee == "user_2"
+					}), "test-user").
+					Return(
+						&domain.Investigation{Status: domain.InvestigationInvestigating, Version: 1, UpdatedAt: &now},
+						&domain.InvestigationEvent{TenantID: fixedTenantID, JobID: fixedJobID, ActorID: "test-user",
+							FromStatus: domain.InvestigationNew, ToStatus: domain.InvestigationInvestigating, Version: 1},
+						nil,
+					)
+			},
+			wantStatus:    http.StatusOK,
+			wantBroadcast: true,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp investigationResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, fixedJobID.String(), resp.JobID)
+				assert.Equal(t, domain.InvestigationInvestigating, resp.Investigation.Status)
+				assert.Equal(t, 1, resp.Investigation.Version)
+			},
+		},
+		{
+			name:  "notes only update keeps status",
+			jobID: fixedJobID.String(),
+			body:  `{"version":3,"notes":"more"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(jobWithInvestigation(domain.InvestigationResolved, 3), nil)
+				pg.On("UpdateJobInvestigation", mock.Anything, fixedTenantID, fixedJobID, 3,
+					mock.MatchedBy(func(u domain.InvestigationUpdate) bool { return u.Status == nil }), "test-user").
+					Return(&domain.Investigation{Status: domain.InvestigationResolved, Version: 4},
+						&domain.InvestigationEvent{Version: 4, NotesChanged: true}, nil)
+			},
+			wantStatus:    http.StatusOK,
+			wantBroadcast: true,
+		},
+		{
+			name:  "stale version returns 409",
+			jobID: fixedJobID.String(),
+			body:  `{"version":1,"status":"resolved"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(jobWithInvestigation(domain.InvestigationInvestigating, 2), nil)
+			},
+			wantStatus: http.StatusConflict,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				errResp := decodeError(t, w)
+				assert.Equal(t, api.ErrCodeConflict, errResp.Code)
+				assert.Equal(t, map[string]any{"current_version": float64(2)}, errResp.Details)
+			},
+		},
+		{
+			name:  "concurrent update in store returns 409",
+			jobID: fixedJobID.String(),
+			body:  `{"version":2,"status":"resolved"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(jobWithInvestigation(domain.InvestigationInvestigating, 2), nil)
+				pg.On("UpdateJobInvestigation", mock.Anything, fixedTenantID, fixedJobID, 2, mock.Anything, "test-user").
+					Return(nil, nil, fmt.Errorf("update: %w", storage.ErrVersionConflict))
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:  "disallowed transition returns 422",
+			jobID: fixedJobID.String(),
+			body:  `{"version":0,"status":"resolved"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(jobWithInvestigation(domain.InvestigationNew, 0), nil)
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, "transition not allowed")
+			},
+		},
+		{
+			name:       "missing version returns 400",
+			jobID:      fixedJobID.String(),
+			body:       `{"status":"investigating"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown status returns 400",
+			jobID:      fixedJobID.String(),
+			body:       `{"version":0,"status":"closed"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid job_id returns 400",
+			jobID:      "nope",
+			body:       `{"version":0}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "unknown job returns 404",
+			jobID: fixedJobID.String(),
+			body:  `{"version":0}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "store error returns 500",
+			jobID: fixedJobID.String(),
+			body:  `{"version":0,"status":"archived"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(jobWithInvestigation(domain.InvestigationNew, 0), nil)
+				pg.On("UpdateJobInvestigation", mock.Anything, fixedTenantID, fixedJobID, 0, mock.Anything, "test-user").
+					Return(nil, nil, errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+			events := &recordingBroadcaster{}
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/analysis/"+tc.jobID+"/investigation", bytes.NewBufferString(tc.body))
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": tc.jobID})
+
+			w := httptest.NewRecorder()
+			NewInvestigationHandlers(pg, events).UpdateInvestigation().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantBroadcast {
+				assert.Equal(t, []string{fixedTenantID.String()}, events.tenants)
+			} else {
+				assert.Empty(t, events.events)
+			}
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInvestigationHandlers_UpdateInvestigation_NotesTooLong(t *testing.T) {
+	body, err := json.Marshal(map[string]any{
+		"version": 0,
+		"notes":   string(bytes.Repeat([]byte("x"), maxInvestigationNotesBytes+1)),
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/analysis/"+fixedJobID.String()+"/investigation", bytes.NewReader(body))
+	req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+
+	w := httptest.NewRecorder()
+	NewInvestigationHandlers(new(testutil.MockPostgresStore), nil).UpdateInvestigation().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, decodeError(t, w).Message, "notes")
+}
+
+func TestInvestigationHandlers_ListHistory(t *testing.T) {
+	t.Run("returns events", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		pg.On("ListInvestigationEvents", mock.Anything, fixedTenantID, fixedJobID).Return([]domain.InvestigationEvent{
+			{ActorID: "user_1", FromStatus: domain.InvestigationNew, ToStatus: domain.InvestigationInvestigating, Version: 1},
+			{ActorID: "user_2", FromStatus: domain.InvestigationInvestigating, ToStatus: domain.InvestigationResolved, Version: 2},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/investigation/history", nil)
+		req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+		w := httptest.NewRecorder()
+		NewInvestigationHandlers(pg, nil).ListHistory().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			JobID  string                      `json:"job_id"`
+			Events []domain.InvestigationEvent `json:"events"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, fixedJobID.String(), resp.JobID)
+		require.Len(t, resp.Events, 2)
+		assert.Equal(t, domain.InvestigationResolved, resp.Events[1].ToStatus)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("unknown job returns 404", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+			Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/investigation/history", nil)
+		req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+		w := httptest.NewRecorder()
+		NewInvestigationHandlers(pg, nil).ListHistory().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		pg.AssertExpectations(t)
+	})
+}
+
+func TestListAnalyses_InvestigationFilter(t *testing.T) {
+	t.Run("filters by status and assignee", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListJobsFiltered", mock.Anything, fixedTenantID, domain.JobListFilter{
+			InvestigationStatus: domain.InvestigationInvestigating,
+			Assignee:            "user_2",
+		}).Return([]domain.AnalysisJob{*jobWithInvestigation(domain.InvestigationInvestigating, 1)}, nil)
+		pg.On("ListAnalysisLinksByJobs", mock.Anything, fixedTenantID, mock.Anything).
+			Return(nil, errors.New("links unavailable"))
+
+		req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/analysis?investigation_status=investigating&assignee=user_2", nil), fixedTenantID.String())
+		w := httptest.NewRecorder()
+		NewAnalysisHandlers(pg, new(testutil.MockNATSStreamer), nil, nil, nil).ListAnalyses().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("unknown status returns 400", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/analysis?investigation_status=closed", nil), fixedTenantID.String())
+		w := httptest.NewRecorder()
+		NewAnalysisHandlers(pg, new(testutil.MockNATSStreamer), nil, nil, nil).ListAnalyses().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		pg.AssertNotCalled(t, "ListJobsFiltered", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// JARReportHandler serves GET /api/v1/analyses/{job_id}/report.{txt|md},
+// the JAR report of a job rendered in canonical form for download.
+//
+// Query parameters:
+//   - sections: comma-separated report sections, by default all of them
+//   - top: rows kept in each ranked table, by default all of them
+type JARReportHandler struct {
+	pg    storage.PostgresStore
+	redis storage.RedisCache
+}
+
+func NewJARReportHandler(pg storage.PostgresStore, redis storage.RedisCache) *JARReportHandler {
+	return &JARReportHandler{pg: pg, redis: redis}
+}
+
+func (h *JARReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	opts := jar.RenderOptions{}
+	var err error
+	contentType := ""
+	switch vars["format"] {
+	case "txt":
+		opts.Format, contentType = jar.ReportText, "text/plain; charset=utf-8"
+	case "md":
+		opts.Format, contentType = jar.ReportMarkdown, "text/markdown; charset=utf-8"
+	default:
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "format must be 'txt' or 'md'")
+		return
+	}
+
+	q := r.URL.Query()
+	var names []string
+	if s := q.Get("sections"); s != "" {
+		names = strings.Split(s, ",")
+	}
+	if opts.Sections, err = jar.ParseReportSections(names); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	if s := q.Get("top"); s != "" {
+		opts.TopN, err = strconv.Atoi(s)
+		if err != nil || opts.TopN < 1 {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "top must be a positive integer")
+			return
+		}
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	if job.Status != domain.JobStatusComplete {
+		sectionNotComplete(w)
+		return
+	}
+
+	result, err := h.parseResult(r.Context(), tid, jobID)
+	if err != nil {
+		slog.Error("jar report: dashboard not cached", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "report data not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"jar-report-%s.%s\"", jobID, vars["format"]))
+	if err := jar.RenderReport(w, result, opts); err != nil {
+		slog.Error("jar report: render failed", "job_id", jobID, "error", err)
+	}
+}
+
+// parseResult rebuilds the parsed JAR report of a job from its cached
+// dashboard and sections. Sections computed from the dashboard rather than
+// read from the JAR are not part of the report and are left out.
+func (h *JARReportHandler) parseResult(ctx context.Context, tid, jobID uuid.UUID) (*domain.ParseResult, error) {
+	tenantID, id := tid.String(), jobID.String()
+
+	dashboard, err := getDashboardFromCache(ctx, h.redis, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.ParseResult{Dashboard: dashboard}
+
+	if data, err := aggregatesSection.load(ctx, h.redis, tenantID, id); err == nil {
+		result.JARAggregates, _ = data.(*domain.JARAggregatesResponse)
+	}
+	if data, err := exceptionsSection.load(ctx, h.redis, tenantID, id); err == nil {
+		result.JARExceptions, _ = data.(*domain.JARExceptionsResponse)
+	}
+	if data, err := gapsSection.load(ctx, h.redis, tenantID, id); err == nil {
+		result.JARGaps, _ = data.(*domain.JARGapsResponse)
+	}
+	if data, err := threadsSection.load(ctx, h.redis, tenantID, id); err == nil {
+		result.JARThreadStats, _ = data.(*domain.JARThreadStatsResponse)
+	}
+	if data, err := filtersSection.load(ctx, h.redis, tenantID, id); err == nil {
+		result.JARFilters, _ = data.(*domain.JARFilterComplexityResponse)
+	}
+
+	if queued, found, _ := getOrComputeQueuedCalls(ctx, h.redis, tenantID, id); found {
+		result.QueuedAPICalls, result.QueuedAPICallsSort = queued.QueuedAPICalls, queued.Sort
+		result.JARQueuedAPICalls = queued.Calls
+	}
+	if activity, found, _ := getOrComputeLoggingActivity(ctx, h.redis, tenantID, id); found {
+		result.LoggingActivities = activity.Activities
+	}
+	if files, found, _ := getOrComputeFileMetadata(ctx, h.redis, tenantID, id); found {
+		result.FileMetadataList = files.Files
+	}
+
+	legend, err := h.pg.GetJobAPILegend(ctx, tid, jobID)
+	if err != nil {
+		slog.Warn("jar report: api legend not available", "job_id", jobID, "error", err)
+	}
+	result.APIAbbreviations = legend
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestJARReportHandler(t *testing.T) {
+	baseKey := "tenant:" + fixedTenantID.String() + ":dashboard:" + fixedJobID.String()
+	dashboardJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+	gapsJSON, err := json.Marshal(domain.JARGapsResponse{
+		LineGaps: []domain.JARGapEntry{{GapDuration: 42.5, GapDurationMS: 42500, LineNumber: 7, TraceID: "T7"}},
+		Source:   "jar_parsed",
+	})
+	require.NoError(t, err)
+
+	expectReport := func(m *handlerMocks) {
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+		m.redis.On("Get", mock.Anything, baseKey).Return(string(dashboardJSON), nil)
+		m.redis.On("Get", mock.Anything, baseKey+":gaps").Return(string(gapsJSON), nil)
+		m.redis.On("Get", mock.Anything, mock.Anything).Return("", errors.New("redis: nil"))
+		m.redis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		m.pg.On("GetJobAPILegend", mock.Anything, fixedTenantID, fixedJobID).Return([]domain.JARAPIAbbreviation{}, nil)
+	}
+
+	tests := []struct {
+		name       string
+		format     string
+		query      string
+		setupMocks func(m *handlerMocks)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "text",
+			format:     "txt",
+			setupMocks: expectReport,
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="jar-report-`+fixedJobID.String()+`.txt"`, w.Header().Get("Content-Disposition"))
+				assert.Contains(t, w.Body.String(), "LONGEST LINE GAPS")
+				assert.Contains(t, w.Body.String(), "General Statistics")
+			},
+		},
+		{
+			name:       "markdown sections",
+			format:     "md",
+			query:      "?sections=gaps&top=1",
+			setupMocks: expectReport,
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), "## GAP ANALYSIS")
+				assert.NotContains(t, w.Body.String(), "General Statistics")
+			},
+		},
+		{
+			name:       "unknown section",
+			format:     "txt",
+			query:      "?sections=charts",
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), `unknown section \"charts\"`)
+			},
+		},
+		{
+			name:       "bad top",
+			format:     "txt",
+			query:      "?top=0",
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown format",
+			format:     "pdf",
+			setupMocks: func(m *handlerMocks) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "not complete",
+			format: "txt",
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsingJob(fixedTenantID, fixedJobID), nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "dashboard missing",
+			format: "txt",
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+				m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+				m.redis.On("Get", mock.Anything, baseKey).Return("", errors.New("redis: nil"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), "report data not available")
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			tc.setupMocks(m)
+
+			w := newTestRequest(http.MethodGet, "/api/v1/analyses/"+fixedJobID.String()+"/report."+tc.format+tc.query).
+				tenant(fixedTenantID.String()).
+				vars("job_id", fixedJobID.String(), "format", tc.format).
+				serve(NewJARReportHandler(m.pg, m.redis))
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			m.assertExpectations(t)
+		})
+	}
+}
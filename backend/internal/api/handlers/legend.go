@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// APILegendHandler serves GET /api/v1/analysis/{job_id}/legend, the API
+// abbreviation legend used for tooltips on API codes.
+type APILegendHandler struct {
+	pg storage.PostgresStore
+}
+
+func NewAPILegendHandler(pg storage.PostgresStore) *APILegendHandler {
+	return &APILegendHandler{pg: pg}
+}
+
+func (h *APILegendHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.pg.GetJobAPILegend(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			slog.Error("failed to retrieve api legend", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve api legend")
+		}
+		return
+	}
+
+	source := "static"
+	if len(entries) > 0 {
+		source = "jar_parsed"
+	}
+	api.JSON(w, http.StatusOK, domain.APILegendResponse{
+		JobID:  jobID.String(),
+		Source: source,
+		Legend: jar.NewAPILegend(entries).Mapping(),
+	})
+}
+
+// lazyAPILegend resolves API codes with a job's legend, loading it on first
+// use so that responses without API calls skip the lookup. A missing or
+// unreadable legend resolves from the static names only.
+type lazyAPILegend struct {
+	ctx      context.Context
+	pg       storage.PostgresStore
+	tenantID string
+	jobID    string
+	legend   jar.APILegend
+	loaded   bool
+}
+
+func newLazyAPILegend(ctx context.Context, pg storage.PostgresStore, tenantID, jobID string) *lazyAPILegend {
+	return &lazyAPILegend{ctx: ctx, pg: pg, tenantID: tenantID, jobID: jobID}
+}
+
+func (l *lazyAPILegend) Resolve(code string) (string, bool) {
+	if code == "" {
+		return code, false
+	}
+	if !l.loaded {
+		l.loaded = true
+		l.legend = l.load()
+	}
+	return l.legend.Resolve(code)
+}
+
+func (l *lazyAPILegend) load() jar.APILegend {
+	if l.pg == nil {
+		return nil
+	}
+	tid, err := middleware.ParseID(l.tenantID)
+	if err != nil {
+		return nil
+	}
+	jid, err := middleware.ParseID(l.jobID)
+	if err != nil {
+		return nil
+	}
+	entries, err := l.pg.GetJobAPILegend(l.ctx, tid, jid)
+	if err != nil {
+		slog.Warn("api legend lookup failed, using static names", "job_id", l.jobID, "error", err)
+		return nil
+	}
+	return jar.NewAPILegend(entries)
+}
+
+// nameSpanAPIs adds an api_name field to spans whose api_code resolves.
+func nameSpanAPIs(spans []domain.SpanNode, legend *lazyAPILegend) {
+	for i := range spans {
+		if code, ok := spans[i].Fields["api_code"].(string); ok {
+			if name, ok := legend.Resolve(code); ok {
+				spans[i].Fields["api_name"] = name
+			}
+		}
+		nameSpanAPIs(spans[i].Children, legend)
+	}
+}
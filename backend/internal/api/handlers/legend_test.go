@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestAPILegendHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		legend     []domain.JARAPIAbbreviation
+		err        error
+		wantStatus int
+		wantSource string
+	}{
+		{
+			name:       "jar legend",
+			legend:     []domain.JARAPIAbbreviation{{Abbreviation: "XQ", FullName: "ARCustomQuery"}},
+			wantStatus: http.StatusOK,
+			wantSource: "jar_parsed",
+		},
+		{
+			name:       "no legend falls back to static names",
+			wantStatus: http.StatusOK,
+			wantSource: "static",
+		},
+		{
+			name:       "job not found",
+			err:        fmt.Errorf("postgres: job not found: %s", fixedJobID),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "lookup fails",
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			pg.On("GetJobAPILegend", mock.Anything, fixedTenantID, fixedJobID).Return(tc.legend, tc.err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/legend", nil)
+			req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String()})
+			w := httptest.NewRecorder()
+			NewAPILegendHandler(pg).ServeHTTP(w, injectAuth(req, fixedTenantID.String()))
+
+			require.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var resp domain.APILegendResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tc.wantSource, resp.Source)
+			assert.Equal(t, "ARGetListEntryWithFields", resp.Legend["GLEWF"])
+			if tc.wantSource == "jar_parsed" {
+				assert.Equal(t, "ARCustomQuery", resp.Legend["XQ"])
+			}
+		})
+	}
+}
+
+func TestLazyAPILegend(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetJobAPILegend", mock.Anything, fixedTenantID, fixedJobID).
+		Return([]domain.JARAPIAbbreviation{{Abbreviation: "XQ", FullName: "ARCustomQuery"}}, nil).Once()
+
+	legend := newLazyAPILegend(context.Background(), pg, fixedTenantID.String(), fixedJobID.String())
+	_, ok := legend.Resolve("")
+	assert.False(t, ok)
+	pg.AssertNotCalled(t, "GetJobAPILegend", mock.Anything, mock.Anything, mock.Anything)
+
+	name, ok := legend.Resolve("XQ")
+	assert.True(t, ok)
+	assert.Equal(t, "ARCustomQuery", name)
+	name, _ = legend.Resolve("+GE")
+	assert.Equal(t, "ARGetEntry", name)
+	pg.AssertExpectations(t)
+
+	spans := []domain.SpanNode{{
+		Fields:   map[string]interface{}{"api_code": "XQ"},
+		Children: []domain.SpanNode{{Fields: map[string]interface{}{"api_code": "ZZ"}}},
+	}}
+	nameSpanAPIs(spans, legend)
+	assert.Equal(t, "ARCustomQuery", spans[0].Fields["api_name"])
+	assert.NotContains(t, spans[0].Children[0].Fields, "api_name")
+
+	noPG := newLazyAPILegend(context.Background(), nil, fixedTenantID.String(), fixedJobID.String())
+	name, ok = noPG.Resolve("SE")
+	assert.True(t, ok)
+	assert.Equal(t, "ARSetEntry", name)
+}
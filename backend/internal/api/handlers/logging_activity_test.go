@@ -45,14 +45,14 @@ func TestLoggingActivityHandler_ServeHTTP(t *testing.T) {
 		Activities: []domain.LoggingActivity{
 			{
 				LogType:        "API",
-				FirstTimestamp: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC),
-				LastTimestamp:  time.Date(2026, 2, 3, 18, 30, 45, 0, time.UTC),
+				FirstTimestamp: domain.NewTimestamp(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)),
+				LastTimestamp:  domain.NewTimestamp(time.Date(2026, 2, 3, 18, 30, 45, 0, time.UTC)),
 				DurationMS:     30645000,
 			},
 			{
 				LogType:        "SQL",
-				FirstTimestamp: time.Date(2026, 2, 3, 10, 0, 1, 0, time.UTC),
-				LastTimestamp:  time.Date(2026, 2, 3, 18, 30, 42, 0, time.UTC),
+				FirstTimestamp: domain.NewTimestamp(time.Date(2026, 2, 3, 10, 0, 1, 0, time.UTC)),
+				LastTimestamp:  domain.NewTimestamp(time.Date(2026, 2, 3, 18, 30, 42, 0, time.UTC)),
 				DurationMS:     30641000,
 			},
 		},
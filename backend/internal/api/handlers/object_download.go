@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// setDownloadURL sets the download URL of a completed export. Backends that
+// cannot pre-sign URLs get apiPath instead, an authenticated API route that
+// streams the object; such URLs do not expire.
+func setDownloadURL(r *http.Request, store storage.ObjectStorage, export *domain.SearchExport, expiry time.Duration, apiPath string) error {
+	if export.Status != domain.ExportStatusComplete || export.S3Key == nil {
+		return nil
+	}
+	url, err := store.PresignGetURL(r.Context(), *export.S3Key, expiry)
+	if errors.Is(err, storage.ErrPresignUnsupported) {
+		export.DownloadURL = apiPath
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().UTC().Add(expiry)
+	export.DownloadURL = url
+	export.URLExpiresAt = &expiresAt
+	return nil
+}
+
+// serveExportObject streams the object of a completed export as an
+// attachment.
+func serveExportObject(w http.ResponseWriter, r *http.Request, store storage.ObjectStorage, export *domain.SearchExport) {
+	if export.Status != domain.ExportStatusComplete || export.S3Key == nil {
+		api.Error(w, http.StatusConflict, api.ErrCodeConflict, "export is not complete")
+		return
+	}
+
+	body, err := store.Download(r.Context(), *export.S3Key)
+	if err != nil {
+		slog.Error("failed to download export object", "export_id", export.ID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to download export")
+		return
+	}
+	defer body.Close()
+
+	name := path.Base(*export.S3Key)
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		slog.Warn("export download interrupted", "export_id", export.ID, "error", err)
+	}
+}
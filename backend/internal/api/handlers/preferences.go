@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/preferences"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// preferencesRequest is the body of PUT /api/v1/preferences and
+// /api/v1/preferences/defaults. SchemaVersion defaults to the namespace's
+// current version.
+type preferencesRequest struct {
+	SchemaVersion *int            `json:"schema_version"`
+	Document      json.RawMessage `json:"document"`
+}
+
+// preferencesResponse is one namespace's preferences: the effective
+// document, merged over the lower layers, and the stored layer itself.
+type preferencesResponse struct {
+	Namespace     string          `json:"namespace"`
+	SchemaVersion int             `json:"schema_version"`
+	Revision      int             `json:"revision"`
+	Document      map[string]any  `json:"document"`
+	Stored        json.RawMessage `json:"stored"`
+	UpdatedAt     *time.Time      `json:"updated_at,omitempty"`
+}
+
+// PreferencesHandlers serve the preferences of the calling user and the
+// tenant defaults they are merged over, one JSON document per registered
+// namespace (see package preferences for the precedence).
+//
+// Every response carries the ETag of the stored document. Writes must send
+// it back in If-Match and fail with 412 if the document was changed since,
+// so that two tabs do not silently overwrite each other.
+type PreferencesHandlers struct {
+	pg storage.PostgresStore
+}
+
+func NewPreferencesHandlers(pg storage.PostgresStore) *PreferencesHandlers {
+	return &PreferencesHandlers{pg: pg}
+}
+
+// Preferences handles GET and PUT /api/v1/preferences?namespace=, the
+// calling user's preferences.
+func (h *PreferencesHandlers) Preferences() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ns, ok := h.request(w, r)
+		if !ok {
+			return
+		}
+		userID := middleware.GetUserID(r.Context())
+		if userID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing user context")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			defaults, ok := h.load(w, r, tenantID, "", ns)
+			if !ok {
+				return
+			}
+			prefs, ok := h.load(w, r, tenantID, userID, ns)
+			if !ok {
+				return
+			}
+			h.write(w, ns, defaults, prefs)
+		case http.MethodPut:
+			prefs, ok := h.store(w, r, tenantID, userID, ns)
+			if !ok {
+				return
+			}
+			if defaults, ok := h.load(w, r, tenantID, "", ns); ok {
+				h.write(w, ns, defaults, prefs)
+			}
+		default:
+			api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+		}
+	})
+}
+
+// Defaults handles GET and PUT /api/v1/preferences/defaults?namespace=, the
+// tenant defaults. Only tenant administrators may change them.
+func (h *PreferencesHandlers) Defaults() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ns, ok := h.request(w, r)
+		if !ok {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if defaults, ok := h.load(w, r, tenantID, "", ns); ok {
+				h.write(w, ns, defaults)
+			}
+		case http.MethodPut:
+			if !requireTenantAdmin(w, r) {
+				return
+			}
+			if defaults, ok := h.store(w, r, tenantID, "", ns); ok {
+				h.write(w, ns, defaults)
+			}
+		default:
+			api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+		}
+	})
+}
+
+// request resolves the tenant and the namespace of a preferences request.
+func (h *PreferencesHandlers) request(w http.ResponseWriter, r *http.Request) (uuid.UUID, preferences.Namespace, bool) {
+	tenantID, ok := requestTenant(w, r)
+	if !ok {
+		return uuid.Nil, preferences.Namespace{}, false
+	}
+	name := r.URL.Query().Get("namespace")
+	ns, ok := preferences.Lookup(name)
+	if !ok {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("namespace must be one of %s", strings.Join(preferences.Names(), ", ")))
+		return uuid.Nil, preferences.Namespace{}, false
+	}
+	return tenantID, ns, true
+}
+
+// load returns the stored preferences of userID, or of the tenant defaults
+// when userID is empty. Nothing stored yields an empty document at
+// revision 0.
+func (h *PreferencesHandlers) load(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, userID string, ns preferences.Namespace) (*domain.Preferences, bool) {
+	prefs, err := h.pg.GetPreferences(r.Context(), tenantID, userID, ns.Name)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return &domain.Preferences{
+				TenantID:      tenantID,
+				UserID:        userID,
+				Namespace:     ns.Name,
+				SchemaVersion: ns.SchemaVersion,
+				Document:      json.RawMessage(`{}`),
+			}, true
+		}
+		slog.Error("get preferences failed", "tenant_id", tenantID, "namespace", ns.Name, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve preferences")
+		return nil, false
+	}
+	return prefs, true
+}
+
+// store writes the request's document if If-Match still names the stored
+// revision.
+func (h *PreferencesHandlers) store(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, userID string, ns preferences.Namespace) (*domain.Preferences, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		api.Error(w, http.StatusPreconditionRequired, api.ErrCodeInvalidRequest, "If-Match is required; send the ETag of the preferences last read")
+		return nil, false
+	}
+	expected, ok := parsePreferencesETag(header)
+	if !ok {
+		api.Error(w, http.StatusPreconditionFailed, api.ErrCodeConflict, "If-Match does not match the stored preferences; reload and retry")
+		return nil, false
+	}
+
+	// The envelope around the document is small; the document itself is
+	// measured once compacted.
+	var req preferencesRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*preferences.MaxDocumentBytes)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			preferencesTooLarge(w)
+			return nil, false
+		}
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return nil, false
+	}
+	var obj map[string]any
+	var doc bytes.Buffer
+	if json.Unmarshal(req.Document, &obj) != nil || obj == nil || json.Compact(&doc, req.Document) != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "document must be a JSON object")
+		return nil, false
+	}
+	if doc.Len() > preferences.MaxDocumentBytes {
+		preferencesTooLarge(w)
+		return nil, false
+	}
+	schemaVersion := ns.SchemaVersion
+	if req.SchemaVersion != nil {
+		schemaVersion = *req.SchemaVersion
+	}
+	if schemaVersion < 1 || schemaVersion > ns.SchemaVersion {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("schema_version must be between 1 and %d", ns.SchemaVersion))
+		return nil, false
+	}
+
+	prefs := &domain.Preferences{
+		TenantID:      tenantID,
+		UserID:        userID,
+		Namespace:     ns.Name,
+		SchemaVersion: schemaVersion,
+		Document:      doc.Bytes(),
+		UpdatedBy:     middleware.GetUserID(r.Context()),
+	}
+	if err := h.pg.PutPreferences(r.Context(), prefs, expected); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			api.Error(w, http.StatusPreconditionFailed, api.ErrCodeConflict, "preferences were changed in another session; reload and retry")
+			return nil, false
+		}
+		slog.Error("put preferences failed", "tenant_id", tenantID, "namespace", ns.Name, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to save preferences")
+		return nil, false
+	}
+	return prefs, true
+}
+
+// write responds with the last of layers, merged over the namespace's
+// built-in defaults and the layers before it.
+func (h *PreferencesHandlers) write(w http.ResponseWriter, ns preferences.Namespace, layers ...*domain.Preferences) {
+	docs := []map[string]any{ns.Defaults}
+	for _, layer := range layers {
+		doc, err := preferences.Decode(layer.Document)
+		if err != nil {
+			// Stored documents were validated on write; a broken one is
+			// left out of the merge rather than failing the read.
+			slog.Warn("stored preferences are not a JSON object", "namespace", ns.Name, "user_id", layer.UserID, "error", err)
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	own := layers[len(layers)-1]
+
+	w.Header().Set("ETag", preferencesETag(own.Revision))
+	noStore(w)
+	api.JSON(w, http.StatusOK, preferencesResponse{
+		Namespace:     ns.Name,
+		SchemaVersion: own.SchemaVersion,
+		Revision:      own.Revision,
+		Document:      preferences.Merge(docs...),
+		Stored:        own.Document,
+		UpdatedAt:     own.UpdatedAt,
+	})
+}
+
+func preferencesTooLarge(w http.ResponseWriter) {
+	api.Error(w, http.StatusRequestEntityTooLarge, api.ErrCodeFileTooLarge,
+		fmt.Sprintf("preferences document exceeds %d bytes", preferences.MaxDocumentBytes))
+}
+
+// preferencesETag is the strong ETag of a stored revision; revision 0 is
+// the tag of a namespace with nothing stored yet.
+func preferencesETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// parsePreferencesETag returns the revision an If-Match header names. Weak
+// tags never match, as If-Match uses the strong comparison.
+func parsePreferencesETag(header string) (int, bool) {
+	tag := strings.TrimSpace(header)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	revision, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || revision < 0 {
+		return 0, false
+	}
+	return revision, true
+}
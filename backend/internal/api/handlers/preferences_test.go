@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/preferences"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func preferencesNotFound(userID string) error {
+	return fmt.Errorf("postgres: preferences not found: %s/log_viewer", userID)
+}
+
+func storedPreferences(userID, doc string, revision int) *domain.Preferences {
+	return &domain.Preferences{
+		TenantID:      fixedTenantID,
+		UserID:        userID,
+		Namespace:     "log_viewer",
+		SchemaVersion: 1,
+		Document:      json.RawMessage(doc),
+		Revision:      revision,
+	}
+}
+
+func decodePreferences(t *testing.T, body []byte) preferencesResponse {
+	t.Helper()
+	var got preferencesResponse
+	require.NoError(t, json.Unmarshal(body, &got))
+	return got
+}
+
+func TestPreferencesHandlers_GetMergesDefaults(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetPreferences", mock.Anything, fixedTenantID, "", "log_viewer").
+		Return(storedPreferences("", `{"page_size":200,"columns":["time","type","user"]}`, 4), nil)
+	pg.On("GetPreferences", mock.Anything, fixedTenantID, "test-user", "log_viewer").
+		Return(storedPreferences("test-user", `{"columns":["time"]}`, 2), nil)
+	h := NewPreferencesHandlers(pg)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/preferences?namespace=log_viewer").
+		tenant(fixedTenantID.String()).
+		serve(h.Preferences())
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"), "the tag is the user's revision")
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	got := decodePreferences(t, w.Body.Bytes())
+	assert.Equal(t, 2, got.Revision)
+	assert.Equal(t, map[string]any{"page_size": float64(200), "columns": []any{"time"}}, got.Document)
+	assert.JSONEq(t, `{"columns":["time"]}`, string(got.Stored))
+	pg.AssertExpectations(t)
+}
+
+func TestPreferencesHandlers_GetNothingStored(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetPreferences", mock.Anything, fixedTenantID, "", "log_viewer").Return(nil, preferencesNotFound(""))
+	pg.On("GetPreferences", mock.Anything, fixedTenantID, "test-user", "log_viewer").Return(nil, preferencesNotFound("test-user"))
+	h := NewPreferencesHandlers(pg)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/preferences?namespace=log_viewer").
+		tenant(fixedTenantID.String()).
+		serve(h.Preferences())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"0"`, w.Header().Get("ETag"))
+	got := decodePreferences(t, w.Body.Bytes())
+	assert.Equal(t, map[string]any{"page_size": float64(100)}, got.Document, "the built-in defaults apply")
+	assert.JSONEq(t, `{}`, string(got.Stored))
+}
+
+func TestPreferencesHandlers_UnknownNamespace(t *testing.T) {
+	h := NewPreferencesHandlers(new(testutil.MockPostgresStore))
+
+	for _, path := range []string{"/api/v1/preferences", "/api/v1/preferences?namespace=reports"} {
+		w := newTestRequest(http.MethodGet, path).tenant(fixedTenantID.String()).serve(h.Preferences())
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), "dashboard, log_viewer, search")
+	}
+}
+
+func TestPreferencesHandlers_Put(t *testing.T) {
+	body := map[string]any{"schema_version": 1, "document": map[string]any{"page_size": 500}}
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		body       any
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		wantETag   string
+	}{
+		{
+			name:    "first write",
+			ifMatch: `"0"`,
+			body:    body,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetPreferences", mock.Anything, fixedTenantID, "", "log_viewer").Return(nil, preferencesNotFound(""))
+				pg.On("PutPreferences", mock.Anything, mock.MatchedBy(func(p *domain.Preferences) bool {
+					return p.UserID == "test-user" && p.UpdatedBy == "test-user" && string(p.Document) == `{"page_size":500}`
+				}), 0).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.Preferences).Revision = 1
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   `"1"`,
+		},
+		{
+			name:    "update",
+			ifMatch: `"3"`,
+			body:    body,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetPreferences", mock.Anything, fixedTenantID, "", "log_viewer").Return(nil, preferencesNotFound(""))
+				pg.On("PutPreferences", mock.Anything, mock.Anything, 3).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.Preferences).Revision = 4
+				}).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   `"4"`,
+		},
+		{
+			name:    "changed in another tab",
+			ifMatch: `"3"`,
+			body:    body,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("PutPreferences", mock.Anything, mock.Anything, 3).Return(storage.ErrVersionConflict)
+			},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "weak tag never matches",
+			ifMatch:    `W/"3"`,
+			body:       body,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "missing If-Match",
+			body:       body,
+			wantStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:       "document too large",
+			ifMatch:    `"0"`,
+			body:       map[string]any{"document": map[string]any{"layout": strings.Repeat("x", preferences.MaxDocumentBytes)}},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "body too large",
+			ifMatch:    `"0"`,
+			body:       map[string]any{"document": map[string]any{"layout": strings.Repeat("x", 3*preferences.MaxDocumentBytes)}},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "document not an object",
+			ifMatch:    `"0"`,
+			body:       map[string]any{"document": []int{1, 2}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "future schema version",
+			ifMatch:    `"0"`,
+			body:       map[string]any{"schema_version": 2, "document": map[string]any{}},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			if tt.setupMocks != nil {
+				tt.setupMocks(pg)
+			}
+			h := NewPreferencesHandlers(pg)
+
+			req := newTestRequest(http.MethodPut, "/api/v1/preferences?namespace=log_viewer").
+				tenant(fixedTenantID.String()).
+				jsonBody(t, tt.body)
+			if tt.ifMatch != "" {
+				req.header("If-Match", tt.ifMatch)
+			}
+			w := req.serve(h.Preferences())
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantETag != "" {
+				assert.Equal(t, tt.wantETag, w.Header().Get("ETag"))
+				got := decodePreferences(t, w.Body.Bytes())
+				assert.Equal(t, map[string]any{"page_size": float64(500)}, got.Document)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPreferencesHandlers_PutDefaults(t *testing.T) {
+	body := map[string]any{"document": map[string]any{"page_size": 250}}
+
+	t.Run("tenant administrator", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("PutPreferences", mock.Anything, mock.MatchedBy(func(p *domain.Preferences) bool {
+			return p.UserID == "" && p.SchemaVersion == 1
+		}), 2).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Preferences).Revision = 3
+		}).Return(nil)
+		h := NewPreferencesHandlers(pg)
+
+		w := newTestRequest(http.MethodPut, "/api/v1/preferences/defaults?namespace=log_viewer").
+			tenant(fixedTenantID.String()).
+			user(fixedTenantID.String()).
+			header("If-Match", `"2"`).
+			jsonBody(t, body).
+			serve(h.Defaults())
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		assert.Equal(t, map[string]any{"page_size": float64(250)}, decodePreferences(t, w.Body.Bytes()).Document)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("other users", func(t *testing.T) {
+		h := NewPreferencesHandlers(new(testutil.MockPostgresStore))
+
+		w := newTestRequest(http.MethodPut, "/api/v1/preferences/defaults?namespace=log_viewer").
+			tenant(fixedTenantID.String()).
+			header("If-Match", `"2"`).
+			jsonBody(t, body).
+			serve(h.Defaults())
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestParsePreferencesETag(t *testing.T) {
+	tests := map[string]int{`"0"`: 0, `"12"`: 12, ` "7" `: 7}
+	for header, want := range tests {
+		got, ok := parsePreferencesETag(header)
+		assert.True(t, ok, header)
+		assert.Equal(t, want, got, header)
+	}
+	for _, header := range []string{`*`, `W/"1"`, `"-1"`, `"x"`, `1`, `"1", "2"`} {
+		_, ok := parsePreferencesETag(header)
+		assert.False(t, ok, header)
+	}
+}
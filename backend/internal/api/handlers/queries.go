@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// QueryHandlers lists and cancels the in-flight search and analytics
+// queries of a tenant.
+type QueryHandlers struct {
+	slots storage.QuerySlotStore
+	ch    storage.ClickHouseStore
+}
+
+// NewQueryHandlers creates the in-flight query handlers.
+func NewQueryHandlers(slots storage.QuerySlotStore, ch storage.ClickHouseStore) *QueryHandlers {
+	return &QueryHandlers{slots: slots, ch: ch}
+}
+
+// ListQueries handles GET /api/v1/analysis/{job_id}/queries, listing the
+// tenant's in-flight queries on the job, oldest first.
+func (h *QueryHandlers) ListQueries() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		all, err := h.slots.ListQuerySlots(r.Context(), tenantID)
+		if err != nil {
+			slog.Error("failed to list in-flight queries", "tenant_id", tenantID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list in-flight queries")
+			return
+		}
+		queries := []domain.InFlightQuery{}
+		for _, q := range all {
+			if q.JobID == jobID.String() {
+				queries = append(queries, q)
+			}
+		}
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"job_id":  jobID,
+			"queries": queries,
+		})
+	})
+}
+
+// KillQuery handles DELETE /api/v1/queries/{query_id}. Only queries of the
+// caller's tenant can be cancelled. The cancellation is asynchronous, so
+// the request may still answer before it lands.
+func (h *QueryHandlers) KillQuery() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+		id, ok := pathID(w, r, "query_id")
+		if !ok {
+			return
+		}
+		queryID := id.String()
+
+		queries, err := h.slots.ListQuerySlots(r.Context(), tenantID)
+		if err != nil {
+			slog.Error("failed to list in-flight queries", "tenant_id", tenantID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list in-flight queries")
+			return
+		}
+		found := false
+		for _, q := range queries {
+			if q.ID == queryID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "query not found or already finished")
+			return
+		}
+
+		if err := h.ch.KillQuery(r.Context(), queryID); err != nil {
+			slog.Error("failed to kill query", "tenant_id", tenantID, "query_id", queryID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to cancel query")
+			return
+		}
+		slog.Info("query cancelled", "tenant_id", tenantID, "query_id", queryID, "user_id", middleware.GetUserID(r.Context()))
+
+		api.JSON(w, http.StatusAccepted, map[string]interface{}{
+			"query_id": queryID,
+			"status":   "cancelling",
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestQueryHandlers_ListQueries(t *testing.T) {
+	m := newHandlerMocks()
+	slots := new(testutil.MockQuerySlotStore)
+	slots.On("ListQuerySlots", mock.Anything, fixedTenantID.String()).Return([]domain.InFlightQuery{
+		{ID: uuid.NewString(), TenantID: fixedTenantID.String(), JobID: fixedJobID.String(), Path: "/search"},
+		{ID: uuid.NewString(), TenantID: fixedTenantID.String(), JobID: uuid.NewString(), Path: "/search"},
+	}, nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/queries").
+		tenant(fixedTenantID.String()).
+		vars("job_id", fixedJobID.String()).
+		serve(NewQueryHandlers(slots, m.ch).ListQueries())
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Queries []domain.InFlightQuery `json:"queries"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Queries, 1, "only the queries of the job are listed")
+	assert.Equal(t, fixedJobID.String(), resp.Queries[0].JobID)
+	slots.AssertExpectations(t)
+
+	w = newTestRequest(http.MethodGet, "/api/v1/analysis/nope/queries").
+		tenant(fixedTenantID.String()).
+		vars("job_id", "nope").
+		serve(NewQueryHandlers(slots, m.ch).ListQueries())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestQueryHandlers_KillQuery(t *testing.T) {
+	queryID := uuid.NewString()
+	running := []domain.InFlightQuery{{ID: queryID, TenantID: fixedTenantID.String(), JobID: fixedJobID.String()}}
+
+	tests := []struct {
+		name       string
+		queryID    string
+		setupMocks func(m *handlerMocks, slots *testutil.MockQuerySlotStore)
+		wantStatus int
+	}{
+		{
+			name:    "kills a running query",
+			queryID: queryID,
+			setupMocks: func(m *handlerMocks, slots *testutil.MockQuerySlotStore) {
+				slots.On("ListQuerySlots", mock.Anything, fixedTenantID.String()).Return(running, nil)
+				m.ch.On("KillQuery", mock.Anything, queryID).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:    "query of another tenant or finished returns 404",
+			queryID: uuid.NewString(),
+			setupMocks: func(m *handlerMocks, slots *testutil.MockQuerySlotStore) {
+				slots.On("ListQuerySlots", mock.Anything, fixedTenantID.String()).Return(running, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid query_id returns 400",
+			queryID:    "nope",
+			setupMocks: func(m *handlerMocks, slots *testutil.MockQuerySlotStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "kill failure returns 500",
+			queryID: queryID,
+			setupMocks: func(m *handlerMocks, slots *testutil.MockQuerySlotStore) {
+				slots.On("ListQuerySlots", mock.Anything, fixedTenantID.String()).Return(running, nil)
+				m.ch.On("KillQuery", mock.Anything, queryID).Return(errors.New("clickhouse down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			slots := new(testutil.MockQuerySlotStore)
+			tc.setupMocks(m, slots)
+
+			w := newTestRequest(http.MethodDelete, "/api/v1/queries/"+tc.queryID).
+				tenant(fixedTenantID.String()).
+				vars("query_id", tc.queryID).
+				serve(NewQueryHandlers(slots, m.ch).KillQuery())
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			m.assertExpectations(t)
+			slots.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+const (
+	// queueStatusCacheKey holds the last queue status; it covers every
+	// tenant, so it is not a tenant key.
+	queueStatusCacheKey = "remedyiq:admin:queue-status"
+
+	// queueStatusCacheTTL is how long operators polling the queue status
+	// share one computation.
+	queueStatusCacheTTL = 5 * time.Second
+
+	// queueThroughputWindow is the window the backlog ETA's throughput is
+	// measured over.
+	queueThroughputWindow = time.Hour
+
+	// queueWorkersWindow is how long a worker that stopped sending
+	// heartbeats stays listed, as dead.
+	queueWorkersWindow = time.Hour
+)
+
+// QueueDepthSource reports the backlog of the job submission stream.
+// streaming.NATSClient implements it.
+type QueueDepthSource interface {
+	JobQueueDepth(ctx context.Context) ([]domain.StreamDepth, error)
+}
+
+// QueueStatusHandler serves GET /api/v1/admin/queue-status, the job queue
+// across all tenants for operators: active jobs by tenant and status, the
+// oldest queued job, running jobs with their stage, worker liveness, the
+// NATS backlog and a backlog ETA.
+//
+// It costs two Postgres queries and one NATS call, and the result is cached
+// in Redis for a few seconds. When NATS cannot be reached the rest is still
+// served, with stream_error set.
+type QueueStatusHandler struct {
+	pg     storage.PostgresStore
+	stream QueueDepthSource
+	redis  storage.RedisCache
+	now    func() time.Time
+}
+
+// NewQueueStatusHandler creates the handler. redis may be nil to disable
+// caching.
+func NewQueueStatusHandler(pg storage.PostgresStore, stream QueueDepthSource, redisCache storage.RedisCache) *QueueStatusHandler {
+	return &QueueStatusHandler{pg: pg, stream: stream, redis: redisCache, now: time.Now}
+}
+
+func (h *QueueStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.redis != nil {
+		if cached, err := h.redis.Get(ctx, queueStatusCacheKey); err == nil {
+			var status domain.QueueStatus
+			if json.Unmarshal([]byte(cached), &status) == nil {
+				noStore(w)
+				api.JSON(w, http.StatusOK, status)
+				return
+			}
+		} else if err != redis.Nil {
+			slog.Warn("redis cache get failed", "key", queueStatusCacheKey, "error", err)
+		}
+	}
+
+	now := h.now().UTC()
+	activity, err := h.pg.GetQueueActivity(ctx, now.Add(-queueThroughputWindow), now.Add(-queueWorkersWindow))
+	if err != nil {
+		slog.Error("get queue activity failed", "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve queue status")
+		return
+	}
+	var (
+		stream    []domain.StreamDepth
+		streamErr error
+	)
+	if h.stream != nil {
+		stream, streamErr = h.stream.JobQueueDepth(ctx)
+	}
+
+	status := worker.BuildQueueStatus(activity, stream, now, worker.HeartbeatStaleAfter)
+	if streamErr != nil {
+		slog.Warn("get job stream depth failed", "error", streamErr)
+		status.StreamError = "job stream depth unavailable"
+	}
+
+	if h.redis != nil {
+		if data, err := json.Marshal(status); err == nil {
+			if err := h.redis.Set(ctx, queueStatusCacheKey, string(data), queueStatusCacheTTL); err != nil {
+				slog.Warn("redis cache set failed", "key", queueStatusCacheKey, "error", err)
+			}
+		}
+	}
+	noStore(w)
+	api.JSON(w, http.StatusOK, status)
+}
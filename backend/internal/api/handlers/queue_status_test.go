@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// fakeQueueDepth is a QueueDepthSource.
+type fakeQueueDepth struct {
+	depths []domain.StreamDepth
+	err    error
+}
+
+func (f *fakeQueueDepth) JobQueueDepth(ctx context.Context) ([]domain.StreamDepth, error) {
+	return f.depths, f.err
+}
+
+func newQueueStatusHandler(pg *testutil.MockPostgresStore, stream QueueDepthSource, rc storage.RedisCache, now time.Time) *QueueStatusHandler {
+	h := NewQueueStatusHandler(pg, stream, rc)
+	h.now = func() time.Time { return now }
+	return h
+}
+
+func TestQueueStatusHandler(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	startedAt := now.Add(-time.Minute)
+	activity := &domain.QueueActivity{
+		Jobs: []domain.ActiveJob{
+			{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusQueued, CreatedAt: now.Add(-10 * time.Minute)},
+			{ID: uuid.New(), TenantID: fixedTenantID, Status: domain.JobStatusStoring, ProgressPct: 90, CreatedAt: now.Add(-time.Hour), StartedAt: &startedAt},
+		},
+		Workers:       []domain.WorkerHeartbeat{{WorkerID: "w1", RunningJobs: 1, MaxJobs: 2, LastSeenAt: now.Add(-time.Second)}},
+		FinishedSince: now.Add(-queueThroughputWindow),
+		FinishedCount: 6,
+	}
+
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetQueueActivity", mock.Anything, now.Add(-queueThroughputWindow), now.Add(-queueWorkersWindow)).Return(activity, nil)
+	rc := new(testutil.MockRedisCache)
+	rc.On("Get", mock.Anything, queueStatusCacheKey).Return("", redis.Nil)
+	rc.On("Set", mock.Anything, queueStatusCacheKey, mock.Anything, queueStatusCacheTTL).Return(nil)
+	stream := &fakeQueueDepth{depths: []domain.StreamDepth{{Consumer: "worker-job-submit-normal", Pending: 1}}}
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/queue-status").serve(newQueueStatusHandler(pg, stream, rc, now))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var got domain.QueueStatus
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 1, got.QueuedJobs)
+	require.NotNil(t, got.OldestQueued)
+	assert.Equal(t, int64(10*60*1000), got.OldestQueued.WaitMS)
+	require.Len(t, got.Running, 1)
+	assert.Equal(t, domain.JobStatusStoring, got.Running[0].Status)
+	assert.Equal(t, 1, got.LiveWorkers)
+	assert.Equal(t, stream.depths, got.Stream)
+	require.NotNil(t, got.BacklogETAMS)
+	assert.Equal(t, int64(10*60*1000), *got.BacklogETAMS)
+	assert.Empty(t, got.StreamError)
+	pg.AssertExpectations(t)
+	rc.AssertExpectations(t)
+}
+
+func TestQueueStatusHandler_Cached(t *testing.T) {
+	cached, err := json.Marshal(domain.QueueStatus{QueuedJobs: 7})
+	require.NoError(t, err)
+	pg := new(testutil.MockPostgresStore)
+	rc := new(testutil.MockRedisCache)
+	rc.On("Get", mock.Anything, queueStatusCacheKey).Return(string(cached), nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/queue-status").serve(newQueueStatusHandler(pg, &fakeQueueDepth{}, rc, time.Now()))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got domain.QueueStatus
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 7, got.QueuedJobs)
+	pg.AssertNotCalled(t, "GetQueueActivity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestQueueStatusHandler_EmptyQueueNoWorkers(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetQueueActivity", mock.Anything, mock.Anything, mock.Anything).
+		Return(&domain.QueueActivity{FinishedSince: now.Add(-queueThroughputWindow)}, nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/queue-status").serve(newQueueStatusHandler(pg, &fakeQueueDepth{}, nil, now))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, []any{}, body["tenants"])
+	assert.Equal(t, []any{}, body["running"])
+	assert.Equal(t, []any{}, body["workers"])
+	assert.Equal(t, []any{}, body["stream"])
+	assert.EqualValues(t, 0, body["live_workers"])
+	assert.EqualValues(t, 0, body["backlog_eta_ms"], "an empty queue is cleared")
+	assert.NotContains(t, body, "oldest_queued")
+}
+
+func TestQueueStatusHandler_StreamUnavailable(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetQueueActivity", mock.Anything, mock.Anything, mock.Anything).Return(&domain.QueueActivity{
+		Jobs:          []domain.ActiveJob{{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusQueued, CreatedAt: now}},
+		FinishedSince: now.Add(-queueThroughputWindow),
+		FinishedCount: 3,
+	}, nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/queue-status").
+		serve(newQueueStatusHandler(pg, &fakeQueueDepth{err: errors.New("nats: timeout")}, nil, now))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got domain.QueueStatus
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, "job stream depth unavailable", got.StreamError)
+	assert.Empty(t, got.Stream)
+	assert.Nil(t, got.BacklogETAMS, "no live worker, no ETA")
+}
+
+func TestQueueStatusHandler_StoreError(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetQueueActivity", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("db down"))
+	rc := new(testutil.MockRedisCache)
+	rc.On("Get", mock.Anything, queueStatusCacheKey).Return("", redis.Nil)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/queue-status").serve(newQueueStatusHandler(pg, &fakeQueueDepth{}, rc, time.Now()))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	rc.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
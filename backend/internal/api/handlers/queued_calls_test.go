@@ -60,6 +60,10 @@ func TestQueuedCallsHandler_ServeHTTP(t *testing.T) {
 				Queue:       "Fast",
 			},
 		},
+		Calls: []domain.JARQueuedAPICall{
+			{Rank: 1, API: "SE", TraceID: "trace-001", Queue: "AR System", WaitMS: 800, RunMS: 1500, TotalMS: 2300},
+			{Rank: 2, API: "GE", TraceID: "trace-002", Queue: "Fast", WaitMS: 600, RunMS: 2000, TotalMS: 2600},
+		},
 		Total: 2,
 	}
 
@@ -91,6 +95,9 @@ func TestQueuedCallsHandler_ServeHTTP(t *testing.T) {
 				assert.Len(t, resp.QueuedAPICalls, 2)
 				assert.Equal(t, 2, resp.Total)
 				assert.Equal(t, "SE", resp.QueuedAPICalls[0].Identifier)
+				require.Len(t, resp.Calls, 2)
+				assert.Equal(t, 800, resp.Calls[0].WaitMS, "the queue wait is served apart from the run time")
+				assert.Equal(t, 1500, resp.Calls[0].RunMS)
 			},
 		},
 		{
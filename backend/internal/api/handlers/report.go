@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,13 +10,11 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
 )
 
 // ReportHandler serves POST /api/v1/analysis/{job_id}/report.
@@ -34,12 +34,14 @@ type reportRequest struct {
 }
 
 // reportResponse represents the response envelope for a generated report.
+// Provenance is the copy embedded in Content plus the SHA-256 of Content.
 type reportResponse struct {
-	JobID     string `json:"job_id"`
-	Format    string `json:"format"`
-	Content   string `json:"content"`
-	Generated string `json:"generated_at"`
-	Skill     string `json:"skill_used"`
+	JobID      string                     `json:"job_id"`
+	Format     string                     `json:"format"`
+	Content    string                     `json:"content"`
+	Generated  string                     `json:"generated_at"`
+	Skill      string                     `json:"skill_used"`
+	Provenance *domain.ArtifactProvenance `json:"provenance"`
 }
 
 // reportData holds all data sections gathered for the report.
@@ -52,6 +54,7 @@ type reportData struct {
 	Gaps       any
 	Threads    any
 	Filters    any
+	Provenance domain.ArtifactProvenance
 }
 
 func (h *ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -61,10 +64,8 @@ func (h *ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
 
@@ -84,9 +85,8 @@ func (h *ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+	tid, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -114,6 +114,7 @@ func (h *ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "report generation failed: no cached data available")
 		return
 	}
+	data.Provenance = reportProvenance(job, req.Format, middleware.GetUserID(r.Context()), data.GeneratedAt)
 
 	var content string
 	if req.Format == "html" {
@@ -132,17 +133,38 @@ func (h *ReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	prov := data.Provenance
+	sum := sha256.Sum256([]byte(content))
+	prov.ContentHash = hex.EncodeToString(sum[:])
+
 	resp := reportResponse{
-		JobID:     jobID.String(),
-		Format:    req.Format,
-		Content:   content,
-		Generated: data.GeneratedAt.Format(time.RFC3339),
-		Skill:     "report_generator",
+		JobID:      jobID.String(),
+		Format:     req.Format,
+		Content:    content,
+		Generated:  data.GeneratedAt.Format(time.RFC3339),
+		Skill:      "report_generator",
+		Provenance: &prov,
 	}
 
 	api.JSON(w, http.StatusOK, resp)
 }
 
+// reportProvenance returns the provenance embedded in a report of job.
+func reportProvenance(job *domain.AnalysisJob, format, userID string, generatedAt time.Time) domain.ArtifactProvenance {
+	analysis := domain.ProvenanceAnalysis{JobID: job.ID}
+	if job.CompletedAt != nil {
+		completed := job.CompletedAt.UTC()
+		analysis.CompletedAt = &completed
+	}
+	return domain.ArtifactProvenance{
+		Format:        "report_" + format,
+		Analyses:      []domain.ProvenanceAnalysis{analysis},
+		ParserVersion: worker.ParserVersion,
+		GeneratedBy:   userID,
+		GeneratedAt:   generatedAt.Truncate(time.Second),
+	}
+}
+
 // gatherReportData reads all cached analysis data from Redis.
 func (h *ReportHandler) gatherReportData(r *http.Request, tenantID, jobID string) (*reportData, error) {
 	ctx := r.Context()
@@ -159,19 +181,19 @@ func (h *ReportHandler) gatherReportData(r *http.Request, tenantID, jobID string
 	}
 
 	// Read section caches (best-effort — sections may not exist for all log types).
-	if agg, err := getOrComputeAggregates(ctx, h.redis, tenantID, jobID); err == nil {
+	if agg, err := aggregatesSection.load(ctx, h.redis, tenantID, jobID); err == nil {
 		data.Aggregates = agg
 	}
-	if exc, err := getOrComputeExceptions(ctx, h.redis, tenantID, jobID); err == nil {
+	if exc, err := exceptionsSection.load(ctx, h.redis, tenantID, jobID); err == nil {
 		data.Exceptions = exc
 	}
-	if gaps, err := getOrComputeGaps(ctx, h.redis, tenantID, jobID); err == nil {
+	if gaps, err := gapsSection.load(ctx, h.redis, tenantID, jobID); err == nil {
 		data.Gaps = gaps
 	}
-	if threads, err := getOrComputeThreads(ctx, h.redis, tenantID, jobID); err == nil {
+	if threads, err := threadsSection.load(ctx, h.redis, tenantID, jobID); err == nil {
 		data.Threads = threads
 	}
-	if filters, err := getOrComputeFilters(ctx, h.redis, tenantID, jobID); err == nil {
+	if filters, err := filtersSection.load(ctx, h.redis, tenantID, jobID); err == nil {
 		data.Filters = filters
 	}
 
@@ -189,6 +211,7 @@ func generateJSONReport(data *reportData) (string, error) {
 		"gaps":         data.Gaps,
 		"threads":      data.Threads,
 		"filters":      data.Filters,
+		"provenance":   data.Provenance,
 	}
 
 	b, err := json.MarshalIndent(report, "", "  ")
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"html/template"
 	"strings"
-	"time"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
@@ -28,7 +27,7 @@ func generateHTMLReport(data *reportData) (string, error) {
 			}
 			return fmt.Sprintf("%.2f s", f/1000)
 		},
-		"fmtTime": func(t time.Time) string {
+		"fmtTime": func(t domain.Timestamp) string {
 			if t.IsZero() {
 				return "-"
 			}
@@ -75,6 +74,7 @@ func generateHTMLReport(data *reportData) (string, error) {
 type templateContext struct {
 	JobID       string
 	GeneratedAt string
+	Provenance  []domain.ProvenanceField
 	Stats       domain.GeneralStatistics
 	TopAPI      []domain.TopNEntry
 	TopSQL      []domain.TopNEntry
@@ -137,6 +137,7 @@ func buildTemplateContext(data *reportData) *templateContext {
 	ctx := &templateContext{
 		JobID:       data.JobID,
 		GeneratedAt: data.GeneratedAt.Format("2006-01-02 15:04:05 UTC"),
+		Provenance:  data.Provenance.Fields(),
 		Stats:       data.Dashboard.GeneralStats,
 		TopAPI:      data.Dashboard.TopAPICalls,
 		TopSQL:      data.Dashboard.TopSQL,
@@ -558,7 +559,7 @@ var reportTemplate = strings.TrimSpace(`
 
   {{if .JARAPIByForm}}
   <div class="section">
-    <div class="section-title">API Aggregates by {{.JARAPIByForm.GroupedBy}} (sorted by {{.JARAPIByForm.SortedBy}})</div>
+    <div class="section-title">API Aggregates by {{.JARAPIByForm.GroupedBy}} (sorted by {{with .JARAPIByForm.SortDirection}}{{.}} {{end}}{{.JARAPIByForm.SortedBy}})</div>
     <div class="section-body">
       <table>
         <thead><tr>
@@ -594,7 +595,7 @@ var reportTemplate = strings.TrimSpace(`
 
   {{if .JARSQLByTable}}
   <div class="section">
-    <div class="section-title">SQL Aggregates by {{.JARSQLByTable.GroupedBy}} (sorted by {{.JARSQLByTable.SortedBy}})</div>
+    <div class="section-title">SQL Aggregates by {{.JARSQLByTable.GroupedBy}} (sorted by {{with .JARSQLByTable.SortDirection}}{{.}} {{end}}{{.JARSQLByTable.SortedBy}})</div>
     <div class="section-body">
       <table>
         <thead><tr>
@@ -1051,6 +1052,11 @@ var reportTemplate = strings.TrimSpace(`
 <!-- Footer -->
 <div class="footer">
   Generated by RemedyIQ &mdash; Log Analysis Platform for BMC Remedy AR Server
+  <table class="provenance">
+    {{range .Provenance}}
+    <tr><td>{{.Name}}</td><td><code>{{.Value}}</code></td></tr>
+    {{end}}
+  </table>
 </div>
 
 </div>
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -467,6 +469,14 @@ func TestReportHandler_SuccessHTML(t *testing.T) {
 	assert.Contains(t, resp.Content, "10000")  // TotalLines
 	assert.Contains(t, resp.Content, "GetEntry") // Top API call
 
+	// Verify the footer carries the provenance and the envelope its hash.
+	assert.Contains(t, resp.Content, "<tr><td>analysis</td><td><code>"+jobID.String()+"</code></td></tr>")
+	assert.Contains(t, resp.Content, "<tr><td>generated_by</td><td><code>test-user</code></td></tr>")
+	require.NotNil(t, resp.Provenance)
+	sum := sha256.Sum256([]byte(resp.Content))
+	assert.Equal(t, hex.EncodeToString(sum[:]), resp.Provenance.ContentHash)
+	assert.Equal(t, "report_html", resp.Provenance.Format)
+
 	pg.AssertExpectations(t)
 }
 
@@ -474,14 +484,16 @@ func TestReportHandler_SuccessJSON(t *testing.T) {
 	tenantID := uuid.New()
 	jobID := uuid.New()
 	now := time.Now()
+	completed := now.Add(-time.Hour).UTC()
 
 	pg := new(testutil.MockPostgresStore)
 	completeJob := &domain.AnalysisJob{
-		ID:        jobID,
-		TenantID:  tenantID,
-		Status:    domain.JobStatusComplete,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:          jobID,
+		TenantID:    tenantID,
+		Status:      domain.JobStatusComplete,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		CompletedAt: &completed,
 	}
 	pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
 
@@ -515,6 +527,19 @@ func TestReportHandler_SuccessJSON(t *testing.T) {
 	assert.Equal(t, jobID.String(), reportJSON["job_id"])
 	assert.NotNil(t, reportJSON["dashboard"])
 
+	// Verify the embedded provenance names the analysis and its completion.
+	var embedded struct {
+		Provenance domain.ArtifactProvenance `json:"provenance"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resp.Content), &embedded))
+	require.Len(t, embedded.Provenance.Analyses, 1)
+	assert.Equal(t, jobID, embedded.Provenance.Analyses[0].JobID)
+	assert.True(t, completed.Equal(*embedded.Provenance.Analyses[0].CompletedAt))
+	assert.Equal(t, "test-user", embedded.Provenance.GeneratedBy)
+	assert.Empty(t, embedded.Provenance.ContentHash)
+	require.NotNil(t, resp.Provenance)
+	assert.Len(t, resp.Provenance.ContentHash, 64)
+
 	pg.AssertExpectations(t)
 }
 
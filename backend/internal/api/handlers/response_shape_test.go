@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files in testdata")
+
+// shapeTime is deliberately not UTC and carries sub-millisecond precision,
+// so the golden files pin down the normalized timestamp format.
+var shapeTime = time.Date(2026, 2, 3, 5, 0, 0, 123456789, time.FixedZone("EST", -5*3600))
+
+func shapeTS(offset time.Duration) domain.Timestamp {
+	return domain.NewTimestamp(shapeTime.Add(offset))
+}
+
+func shapeTSPtr(offset time.Duration) *domain.Timestamp {
+	ts := shapeTS(offset)
+	return &ts
+}
+
+func shapeLogEntry() domain.LogEntry {
+	return domain.LogEntry{
+		TenantID: fixedTenantID.String(), JobID: fixedJobID.String(), EntryID: "e-1",
+		LineNumber: 42, FileNumber: 1, Timestamp: shapeTS(0), IngestedAt: shapeTS(time.Hour),
+		LogType: domain.LogTypeEscalation, TraceID: "trace-1", RPCID: "rpc-1", ThreadID: "T001",
+		Queue: "Escalation", User: "AR_ESCALATOR", DurationMS: 150, Success: true,
+		EscName: "HPD:Escalate", EscPool: "1", ScheduledTime: shapeTSPtr(-2 * time.Second), DelayMS: 2000,
+	}
+}
+
+// responseShapes holds one representative response per endpoint whose
+// payload carries timestamps or durations.
+func responseShapes() map[string]interface{} {
+	return map[string]interface{}{
+		"dashboard": domain.DashboardData{
+			GeneralStats: domain.GeneralStatistics{
+				TotalLines: 1000, APICount: 600, LogStart: shapeTS(0), LogEnd: shapeTS(8*time.Hour + 30*time.Minute + 45*time.Second),
+				LogDuration: "8h 30m 45s", LogDurationMS: 30645000,
+			},
+			TopAPICalls:    []domain.TopNEntry{{Rank: 1, LineNumber: 10, Timestamp: shapeTS(time.Minute), Identifier: "GE", DurationMS: 5000, Success: true}},
+			TopSQL:         []domain.TopNEntry{},
+			TopFilters:     []domain.TopNEntry{},
+			TopEscalations: []domain.TopNEntry{},
+			TimeSeries:     []domain.TimeSeriesPoint{{Timestamp: shapeTS(0), APICount: 10, AvgDurationMS: 12.5}},
+			Distribution:   map[string]map[string]int{"by_type": {"API": 600}},
+			FirstErrorAt:   shapeTSPtr(5 * time.Minute),
+		},
+		"gaps": domain.GapsResponse{
+			Gaps: []domain.GapEntry{{
+				StartTime: shapeTS(0), EndTime: shapeTS(30 * time.Second), DurationMS: 30000,
+				BeforeLine: 100, AfterLine: 101, LogType: domain.LogTypeAPI,
+			}},
+			QueueHealth: []domain.QueueHealthSummary{},
+		},
+		"threads": domain.ThreadStatsResponse{
+			Threads: []domain.ThreadStatsEntry{
+				{ThreadID: "T001", TotalCalls: 20, TotalMS: 4000, AvgMS: 200, MaxMS: 900, BusyPct: 42.5, ActiveStart: shapeTSPtr(0), ActiveEnd: shapeTSPtr(time.Hour)},
+				{ThreadID: "T002", TotalCalls: 1},
+			},
+			TotalThreads: 2,
+		},
+		"exceptions": domain.ExceptionsResponse{
+			Exceptions: []domain.ExceptionEntry{{ErrorCode: "ARERR 302", Message: "Entry does not exist", Count: 3, FirstSeen: shapeTS(0), LastSeen: shapeTS(time.Minute), LogType: domain.LogTypeAPI}},
+			TotalCount: 3,
+			ErrorRates: map[string]float64{"API": 0.5},
+			TopCodes:   []string{"ARERR 302"},
+		},
+		"error_heatmap": domain.ErrorHeatmapResponse{
+			BucketSize: "1 MINUTE",
+			Buckets:    []domain.Timestamp{shapeTS(0), shapeTS(time.Minute)},
+			Rows:       []domain.ErrorHeatmapRow{{ErrorCode: "ARERR 302", Counts: []int64{1, 2}, Total: 3, PeakBucket: 1}},
+			TotalCount: 3,
+		},
+		"thread_timeline": domain.ThreadTimelineResponse{
+			From: shapeTS(0), To: shapeTS(time.Hour), GapMS: 1000,
+			Threads: []domain.ThreadTimeline{{
+				ThreadID: "T001", Queue: "Fast", TotalEntries: 5, TotalMS: 800,
+				Segments: []domain.ThreadSegment{{Start: shapeTS(0), End: shapeTS(time.Second), EntryCount: 5, DominantLogType: domain.LogTypeAPI}},
+			}},
+		},
+		"error_onset": domain.ErrorOnset{
+			JobID: fixedJobID.String(), CaptureStart: shapeTS(0), CaptureEnd: shapeTS(time.Hour),
+			TotalEntries: 100, TotalErrors: 4,
+			FirstError: &domain.FirstError{Key: "API", Timestamp: shapeTS(5 * time.Minute), EntryID: "e-7", LineNumber: 70, FileNumber: 1, ErrorCount: 4},
+			ByLogType:  []domain.FirstError{{Key: "API", Timestamp: shapeTS(5 * time.Minute), EntryID: "e-7", LineNumber: 70, FileNumber: 1, ErrorCount: 4}},
+			ByForm:     []domain.FirstError{},
+			ByQueue:    []domain.FirstError{},
+			BucketMS:   60000, WindowMS: 300000,
+			Curve:      []domain.ErrorOnsetPoint{{Timestamp: shapeTS(0)}, {Timestamp: shapeTS(time.Hour), Offset: 1, CumulativeErrors: 4, Fraction: 1}},
+			Thresholds: []domain.ErrorRateCrossing{{Threshold: 0.01, CrossedAt: shapeTSPtr(5 * time.Minute), Rate: 0.04, WindowEntries: 100, WindowErrors: 4}, {Threshold: 0.5}},
+			ComputedAt: shapeTS(2 * time.Hour),
+		},
+		"delayed_escalations": domain.DelayedEscalationsResponse{
+			JobID: fixedJobID.String(),
+			Entries: []domain.DelayedEscalationEntry{{
+				EscName: "HPD:Escalate", EscPool: "1", ScheduledTime: shapeTSPtr(-2 * time.Second), ActualTime: shapeTS(0),
+				DelayMS: 2000, ThreadID: "T001", TraceID: "trace-1", LineNumber: 42,
+			}},
+			Total: 1, AvgDelayMS: 2000, MaxDelayMS: 2000,
+		},
+		"trace_waterfall": domain.WaterfallResponse{
+			TraceID: "trace-1", CorrelationType: "trace_id", TotalDurationMS: 150, SpanCount: 1,
+			TypeBreakdown: map[string]int{"ESCL": 1},
+			TraceStart:    shapeTS(0), TraceEnd: shapeTS(150 * time.Millisecond),
+			Spans: []domain.SpanNode{{
+				ID: "e-1", LogType: domain.LogTypeEscalation, DurationMS: 150, Fields: map[string]interface{}{"esc_name": "HPD:Escalate"},
+				Children: []domain.SpanNode{}, Timestamp: shapeTS(0), ThreadID: "T001", TraceID: "trace-1", LineNumber: 42, FileNumber: 1, Success: true,
+			}},
+			FlatSpans:    []domain.SpanNode{},
+			CriticalPath: []string{"e-1"},
+		},
+		"transactions": domain.TransactionSearchResponse{
+			Transactions: []domain.TransactionSummary{{
+				TraceID: "trace-1", CorrelationType: "trace_id", PrimaryUser: "Demo", TotalDurationMS: 150, SpanCount: 1,
+				FirstTimestamp: shapeTS(0), LastTimestamp: shapeTS(150 * time.Millisecond),
+			}},
+			Total: 1,
+		},
+		"logging_activity": domain.LoggingActivityResponse{
+			JobID:      fixedJobID.String(),
+			Activities: []domain.LoggingActivity{{LogType: "API", FirstTimestamp: shapeTS(0), LastTimestamp: shapeTS(time.Hour), DurationMS: 3600000, EntryCount: 600}},
+		},
+		"file_metadata": domain.FileMetadataResponse{
+			JobID: fixedJobID.String(),
+			Files: []domain.FileMetadata{{FileNumber: 1, FileName: "arapi.log", StartTime: shapeTS(0), EndTime: shapeTS(time.Hour), DurationMS: 3600000, EntryCount: 600}},
+			Total: 1,
+		},
+		"histogram": domain.HistogramResponse{
+			Buckets:    []domain.HistogramBucket{{Timestamp: shapeTS(0), Counts: domain.HistogramCounts{API: 3, Total: 3}}},
+			BucketSize: "1m",
+		},
+		"entry": shapeLogEntry(),
+		"entry_context": domain.ContextResponse{
+			Target: shapeLogEntry(), Before: []domain.LogEntry{}, After: []domain.LogEntry{}, WindowSize: 10,
+		},
+		"jar_gaps": domain.JARGapsResponse{
+			LineGaps:    []domain.JARGapEntry{{GapDuration: 0.265, GapDurationMS: 265, LineNumber: 12, TraceID: "trace-1", Timestamp: shapeTS(0), Details: "GE"}},
+			ThreadGaps:  []domain.JARGapEntry{},
+			QueueHealth: []domain.QueueHealthSummary{},
+			Source:      "jar_parsed",
+		},
+		"jar_aggregates": domain.JARAggregatesResponse{
+			APIByForm: &domain.JARAggregateTable{
+				GroupedBy: "Form", SortedBy: "AvgTime",
+				Groups:     []domain.JARAggregateGroup{},
+				GrandTotal: &domain.JARAggregateRow{OperationType: "GE", OK: 2, Total: 2, MinTime: 0.01, MaxTime: 0.21, AvgTime: 0.11, SumTime: 0.22, MinTimeMS: 10, MaxTimeMS: 210, AvgTimeMS: 110, SumTimeMS: 220},
+			},
+			Source: "jar_parsed",
+		},
+		"jar_threads": domain.JARThreadStatsResponse{
+			APIThreads: []domain.JARThreadStat{{Queue: "Fast", ThreadID: "T001", FirstTime: shapeTS(0), LastTime: shapeTS(time.Hour), Count: 20, QTime: 0.5, TotalTime: 6.169, BusyPct: 1.2, QTimeMS: 500, TotalTimeMS: 6169}},
+			SQLThreads: []domain.JARThreadStat{},
+			Source:     "jar_parsed",
+		},
+		"jar_exceptions": domain.JARExceptionsResponse{
+			APIErrors:     []domain.JARAPIError{{EndLine: 70, TraceID: "trace-1", Queue: "Fast", API: "SE", Form: "HPD:Help Desk", StartTime: shapeTS(0), ErrorMessage: "ARERR 302"}},
+			APIExceptions: []domain.JARExceptionEntry{},
+			SQLExceptions: []domain.JARExceptionEntry{},
+			Source:        "jar_parsed",
+		},
+	}
+}
+
+// TestResponseShapes_Golden pins the JSON of every response carrying
+// timestamps or durations, so that a change in field names or formats
+// shows up as a diff. Run with -update-golden after intended changes.
+func TestResponseShapes_Golden(t *testing.T) {
+	for name, resp := range responseShapes() {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			api.JSON(w, http.StatusOK, resp)
+			assertGoldenJSON(t, name, w.Body.Bytes())
+		})
+	}
+}
+
+// TestResponseShapes_TimestampFormat checks that every timestamp in the
+// responses is RFC 3339 in UTC with millisecond precision.
+func TestResponseShapes_TimestampFormat(t *testing.T) {
+	anyTimestamp := regexp.MustCompile(`"\d{4}-\d{2}-\d{2}[T ][^"]*"`)
+	wantTimestamp := regexp.MustCompile(`^"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z"$`)
+	for name, resp := range responseShapes() {
+		b, err := json.Marshal(resp)
+		require.NoError(t, err)
+		for _, ts := range anyTimestamp.FindAllString(string(b), -1) {
+			assert.Regexp(t, wantTimestamp, ts, name)
+		}
+	}
+}
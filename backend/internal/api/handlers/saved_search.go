@@ -41,9 +41,8 @@ func (h *SavedSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tenantUUID, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant ID")
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -130,9 +129,8 @@ func (h *DeleteSavedSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	tenantUUID, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant ID")
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -143,9 +141,8 @@ func (h *DeleteSavedSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	searchID, err := uuid.Parse(searchIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid search_id")
+	searchID, ok := pathID(w, r, "search_id")
+	if !ok {
 		return
 	}
 
@@ -183,9 +180,8 @@ func (h *SearchHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tenantUUID, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant ID")
+	tenantUUID, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -12,12 +13,12 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
@@ -30,18 +31,45 @@ type SearchLogsHandler struct {
 	bleve search.SearchIndexer
 	redis storage.RedisCache
 	pg    storage.PostgresStore
+
+	// settings gives the cache TTL of the results; nil means
+	// searchCacheTTL.
+	settings config.Dynamic
 }
 
 func NewSearchLogsHandler(ch storage.ClickHouseStore, bleve search.SearchIndexer, rc storage.RedisCache, pg storage.PostgresStore) *SearchLogsHandler {
 	return &SearchLogsHandler{ch: ch, bleve: bleve, redis: rc, pg: pg}
 }
 
+// SetDynamic makes the handler read the cache TTL of the results from
+// settings at each search.
+func (h *SearchLogsHandler) SetDynamic(settings config.Dynamic) {
+	h.settings = settings
+}
+
+func (h *SearchLogsHandler) cacheTTL() time.Duration {
+	if h.settings != nil {
+		return h.settings.SearchCacheTTL()
+	}
+	return searchCacheTTL
+}
+
 type SearchRequest struct {
-	Query    string `json:"query"`
-	Page     int    `json:"page"`
-	PageSize int    `json:"page_size"`
-	SortBy   string `json:"sort_by"`
-	SortDir  string `json:"sort_dir"`
+	Query        string `json:"query"`
+	Page         int    `json:"page"`
+	PageSize     int    `json:"page_size"`
+	SortBy       string `json:"sort_by"`
+	SortDir      string `json:"sort_dir"`
+	IncludeNoise bool   `json:"include_noise"`
+	Suggest      bool   `json:"suggest"`
+
+	// SecondarySortBy orders the hits that tie on sort_by.
+	SecondarySortBy  string `json:"secondary_sort_by,omitempty"`
+	SecondarySortDir string `json:"secondary_sort_dir,omitempty"`
+
+	// Computed are expressions evaluated for each hit; sort_by may name
+	// one of them.
+	Computed []search.ComputedColumn `json:"computed,omitempty"`
 }
 
 type SearchResponse struct {
@@ -53,12 +81,32 @@ type SearchResponse struct {
 	Facets     map[string][]FacetEntry  `json:"facets,omitempty"`
 	Histogram  []domain.HistogramBucket `json:"histogram,omitempty"`
 	TookMS     int                      `json:"took_ms"`
+
+	// Suggestions are the refinements of the search, asked for with
+	// suggest=true.
+	Suggestions []search.Suggestion `json:"suggestions,omitempty"`
+
+	// QueryInterpretation tells how the free text of the query matched,
+	// when it has any.
+	QueryInterpretation *search.QueryInterpretation `json:"query_interpretation,omitempty"`
+
+	// TimeRange echoes time_from and time_to with the times they resolved
+	// to, when either was given.
+	TimeRange *ResolvedTimeRange `json:"time_range,omitempty"`
+
+	// Sort is the order of the results: the requested columns, then the
+	// timestamp, line_number and entry_id tiebreakers. Strings sort
+	// case-insensitively.
+	Sort []domain.SortKey `json:"sort"`
 }
 
 type SearchHit struct {
 	ID     string                 `json:"id"`
 	Score  float64                `json:"score"`
 	Fields map[string]interface{} `json:"fields"`
+
+	// Computed holds the values of the request's computed columns.
+	Computed map[string]any `json:"computed,omitempty"`
 }
 
 type FacetEntry struct {
@@ -82,10 +130,11 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	var query string
 	var page, pageSize int
-	var sortBy, sortDir string
-	var timeFrom, timeTo *time.Time
-	var includeHistogram bool
+	var sortBy, sortDir, thenBy, thenDir string
+	var bounds timeBounds
+	var includeHistogram, includeNoise, suggest bool
 	var logTypes, users, queues []string
+	var computed []search.ComputedColumn
 
 	if r.Method == http.MethodGet {
 		query = r.URL.Query().Get("q")
@@ -93,25 +142,22 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		pageSize, _ = strconv.Atoi(r.URL.Query().Get("page_size"))
 		sortBy = r.URL.Query().Get("sort_by")
 		sortDir = r.URL.Query().Get("sort_order")
+		thenBy = r.URL.Query().Get("secondary_sort_by")
+		thenDir = r.URL.Query().Get("secondary_sort_order")
 		includeHistogram = r.URL.Query().Get("include_histogram") == "true"
+		includeNoise = r.URL.Query().Get("include_noise") == "true"
+		suggest = r.URL.Query().Get("suggest") == "true"
 		logTypes = r.URL.Query()["log_type"]
 		users = r.URL.Query()["user"]
 		queues = r.URL.Query()["queue"]
-		if fromStr := r.URL.Query().Get("time_from"); fromStr != "" {
-			t, err := time.Parse(time.RFC3339, fromStr)
-			if err != nil {
-				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid time_from format, expected RFC3339")
-				return
-			}
-			timeFrom = &t
+		// computed=name:expr, repeated.
+		for _, param := range r.URL.Query()["computed"] {
+			name, expr, _ := strings.Cut(param, ":")
+			computed = append(computed, search.ComputedColumn{Name: strings.TrimSpace(name), Expr: expr})
 		}
-		if toStr := r.URL.Query().Get("time_to"); toStr != "" {
-			t, err := time.Parse(time.RFC3339, toStr)
-			if err != nil {
-				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid time_to format, expected RFC3339")
-				return
-			}
-			timeTo = &t
+		var ok bool
+		if bounds, ok = parseTimeBounds(w, r.URL.Query(), "time_from", "time_to"); !ok {
+			return
 		}
 	} else {
 		var req SearchRequest
@@ -124,6 +170,11 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		pageSize = req.PageSize
 		sortBy = req.SortBy
 		sortDir = req.SortDir
+		thenBy = req.SecondarySortBy
+		thenDir = req.SecondarySortDir
+		includeNoise = req.IncludeNoise
+		suggest = req.Suggest
+		computed = req.Computed
 	}
 
 	if query == "" {
@@ -137,32 +188,56 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		pageSize = 50
 	}
 
-	validSortFields := map[string]bool{
-		"timestamp":   true,
-		"duration_ms": true,
-		"line_number": true,
-		"user":        true,
-		"log_type":    true,
+	compiled, err := search.CompileComputed(computed)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid computed column: "+err.Error())
+		return
+	}
+	validSortField := func(name string) bool {
+		if storage.IsSortField(name) {
+			return true
+		}
+		for _, col := range compiled {
+			if col.Name == name {
+				return true
+			}
+		}
+		return false
 	}
-	if !validSortFields[sortBy] {
+	if !validSortField(sortBy) {
 		sortBy = "timestamp"
 	}
+	if thenBy != "" && !validSortField(thenBy) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid secondary_sort_by: "+thenBy)
+		return
+	}
+	if thenDir != "asc" && thenDir != "desc" {
+		thenDir = "desc"
+	}
 	if sortDir != "asc" && sortDir != "desc" {
 		sortDir = "desc"
 	}
 
-	_, err := search.ParseKQL(query)
+	parsed, err := search.ParseKQL(query)
 	if err != nil {
 		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid query syntax: "+err.Error())
 		return
 	}
 
+	timeFrom, timeTo, ok := resolveTimeBounds(w, r, h.ch, tenantID, jobID, bounds)
+	if !ok {
+		return
+	}
+
 	// Check Redis cache before executing search
-	cacheKey := h.buildCacheKey(tenantID, jobID, query, page, pageSize, sortBy, sortDir, timeFrom, timeTo, includeHistogram, logTypes, users, queues)
+	cacheKey := h.buildCacheKey(tenantID, jobID, query, page, pageSize, sortBy, sortDir, thenBy, thenDir, timeFrom, timeTo, includeHistogram, includeNoise, suggest, logTypes, users, queues, computed)
 	if h.redis != nil {
 		if cached, err := h.redis.Get(r.Context(), cacheKey); err == nil {
 			var resp SearchResponse
 			if json.Unmarshal([]byte(cached), &resp) == nil {
+				// The key holds the resolved bounds; echo this request's
+				// expressions for them.
+				resp.TimeRange = bounds.echo(timeFrom, timeTo)
 				api.JSON(w, http.StatusOK, resp)
 				return
 			}
@@ -173,33 +248,52 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Use ClickHouse as primary data source for entries and total count.
 	chQuery := storage.SearchQuery{
-		Query:     query,
-		LogTypes:  logTypes,
-		Users:     users,
-		Queues:    queues,
-		Page:      page,
-		PageSize:  pageSize,
-		SortBy:    sortBy,
-		SortOrder: sortDir,
-		TimeFrom:  timeFrom,
-		TimeTo:    timeTo,
+		Query:        query,
+		LogTypes:     logTypes,
+		Users:        users,
+		Queues:       queues,
+		Page:         page,
+		PageSize:     pageSize,
+		SortBy:       sortBy,
+		SortOrder:    sortDir,
+		TimeFrom:     timeFrom,
+		TimeTo:       timeTo,
+		IncludeNoise: includeNoise,
+		Computed:     compiled,
+
+		SecondarySortBy:    thenBy,
+		SecondarySortOrder: thenDir,
 	}
 
 	chResult, err := h.ch.SearchEntries(r.Context(), tenantID, jobID, chQuery)
 	if err != nil {
+		if errors.Is(err, storage.ErrSearchTooBroad) {
+			slog.Warn("search too broad", "error", err, "tenant_id", tenantID, "job_id", jobID, "query", query)
+			api.Error(w, http.StatusUnprocessableEntity, api.ErrCodeQueryTooBroad, "query too broad, please add filters")
+			return
+		}
 		slog.Error("search entries failed", "error", err, "tenant_id", tenantID, "job_id", jobID, "query", query)
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "search failed")
 		return
 	}
 
 	// Convert ClickHouse entries to SearchHit format
+	legend := newLazyAPILegend(r.Context(), h.pg, tenantID, jobID)
 	hits := make([]SearchHit, 0, len(chResult.Entries))
-	for _, entry := range chResult.Entries {
-		hits = append(hits, SearchHit{
+	for i, entry := range chResult.Entries {
+		fields := entryToFieldMap(entry)
+		if name, ok := legend.Resolve(entry.APICode); ok {
+			fields["api_name"] = name
+		}
+		hit := SearchHit{
 			ID:     entry.EntryID,
 			Score:  1.0,
-			Fields: entryToFieldMap(entry),
-		})
+			Fields: fields,
+		}
+		if i < len(chResult.Computed) {
+			hit.Computed = chResult.Computed[i]
+		}
+		hits = append(hits, hit)
 	}
 
 	totalPages := int(chResult.TotalCount) / pageSize
@@ -209,7 +303,8 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Use ClickHouse for facets (replaces Bleve which requires separate indexing)
 	facets := make(map[string][]FacetEntry)
-	if chFacets, err := h.ch.GetFacets(r.Context(), tenantID, jobID, chQuery); err == nil {
+	chFacets, facetErr := h.ch.GetFacets(r.Context(), tenantID, jobID, chQuery)
+	if facetErr == nil {
 		for field, values := range chFacets {
 			entries := make([]FacetEntry, 0, len(values))
 			for _, v := range values {
@@ -259,14 +354,21 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Facets:     facets,
 		Histogram:  histogram,
 		TookMS:     chResult.TookMS,
+
+		QueryInterpretation: search.Interpret(parsed),
+		TimeRange:           bounds.echo(timeFrom, timeTo),
+		Sort:                chQuery.EffectiveSort(),
+	}
+	if suggest && chResult.TotalCount >= search.SuggestMinResults {
+		resp.Suggestions = h.suggest(r.Context(), tenantID, jobID, query, chQuery, chResult.TotalCount, chFacets)
 	}
 
 	// Record search history (non-blocking, best-effort)
 	if h.pg != nil && query != "*" {
 		userID := middleware.GetUserID(r.Context())
 		if userID != "" {
-			if tenantUUID, err := uuid.Parse(tenantID); err == nil {
-				jobUUID, _ := uuid.Parse(jobID)
+			if tenantUUID, err := middleware.ParseID(tenantID); err == nil {
+				jobUUID, _ := middleware.ParseID(jobID)
 				go func() {
 					bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
@@ -281,7 +383,7 @@ func (h *SearchLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Cache the response in Redis
 	if h.redis != nil {
 		if data, err := json.Marshal(resp); err == nil {
-			if err := h.redis.Set(r.Context(), cacheKey, string(data), searchCacheTTL); err != nil {
+			if err := h.redis.Set(r.Context(), cacheKey, string(data), h.cacheTTL()); err != nil {
 				slog.Warn("redis cache set failed", "key", cacheKey, "error", err)
 			}
 		}
@@ -321,6 +423,12 @@ func entryToFieldMap(e domain.LogEntry) map[string]interface{} {
 	if e.Form != "" {
 		m["form"] = e.Form
 	}
+	if e.Client != "" {
+		m["client"] = e.Client
+	}
+	if e.ClientIP != "" {
+		m["client_ip"] = e.ClientIP
+	}
 	if e.SQLTable != "" {
 		m["sql_table"] = e.SQLTable
 	}
@@ -351,10 +459,16 @@ func entryToFieldMap(e domain.LogEntry) map[string]interface{} {
 	if e.ErrorMessage != "" {
 		m["error_message"] = e.ErrorMessage
 	}
+	if e.RawTextTruncated {
+		m["raw_text_truncated"] = true
+	}
+	if e.IsNoise {
+		m["is_noise"] = true
+	}
 	return m
 }
 
-func (h *SearchLogsHandler) buildCacheKey(tenantID, jobID, query string, page, pageSize int, sortBy, sortDir string, timeFrom, timeTo *time.Time, includeHistogram bool, logTypes, users, queues []string) string {
+func (h *SearchLogsHandler) buildCacheKey(tenantID, jobID, query string, page, pageSize int, sortBy, sortDir, thenBy, thenDir string, timeFrom, timeTo *time.Time, includeHistogram, includeNoise, suggest bool, logTypes, users, queues []string, computed []search.ComputedColumn) string {
 	var fromStr, toStr string
 	if timeFrom != nil {
 		fromStr = timeFrom.UTC().Format(time.RFC3339Nano)
@@ -371,9 +485,37 @@ func (h *SearchLogsHandler) buildCacheKey(tenantID, jobID, query string, page, p
 	sortedQueues := make([]string, len(queues))
 	copy(sortedQueues, queues)
 	sort.Strings(sortedQueues)
-	raw := fmt.Sprintf("%s|%s|%s|%d|%d|%s|%s|%s|%s|%v|%s|%s|%s",
-		tenantID, jobID, query, page, pageSize, sortBy, sortDir, fromStr, toStr, includeHistogram,
-		strings.Join(sortedTypes, ","), strings.Join(sortedUsers, ","), strings.Join(sortedQueues, ","))
+	computedJSON, _ := json.Marshal(computed)
+	raw := fmt.Sprintf("%s|%s|%s|%d|%d|%s|%s|%s|%s|%s|%s|%v|%v|%v|%s|%s|%s|%s",
+		tenantID, jobID, query, page, pageSize, sortBy, sortDir, thenBy, thenDir, fromStr, toStr, includeHistogram, includeNoise, suggest,
+		strings.Join(sortedTypes, ","), strings.Join(sortedUsers, ","), strings.Join(sortedQueues, ","), computedJSON)
 	hash := sha256.Sum256([]byte(raw))
 	return fmt.Sprintf("cache:%s:search:%x", tenantID, hash[:8])
 }
+
+// suggest returns the refinements of a search from its facets and the
+// duration and failure counts of its results. Suggestions are best-effort:
+// without those counts only facet refinements are offered.
+func (h *SearchLogsHandler) suggest(ctx context.Context, tenantID, jobID, query string, q storage.SearchQuery, total int64, facets map[string][]storage.FacetValue) []search.Suggestion {
+	in := search.RefinementInput{
+		Query:  query,
+		Total:  total,
+		Facets: make(map[string][]search.FacetCount, len(facets)),
+	}
+	for field, values := range facets {
+		counts := make([]search.FacetCount, len(values))
+		for i, v := range values {
+			counts[i] = search.FacetCount{Value: v.Value, Count: v.Count}
+		}
+		in.Facets[field] = counts
+	}
+	if stats, err := h.ch.GetRefinementStats(ctx, tenantID, jobID, q); err != nil {
+		slog.Warn("refinement stats query failed", "job_id", jobID, "error", err)
+	} else {
+		in.Failed = stats.Failed
+		for _, d := range stats.SlowerThan {
+			in.SlowerThan = append(in.SlowerThan, search.DurationCount{ThresholdMS: d.ThresholdMS, Count: d.Count})
+		}
+	}
+	return search.SuggestRefinements(in)
+}
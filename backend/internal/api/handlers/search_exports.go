@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+// maxListedExports caps the number of exports returned by ListExports.
+const maxListedExports = 100
+
+// searchExportCreateRequest is the body of POST /api/v1/analysis/{job_id}/exports.
+type searchExportCreateRequest struct {
+	Format string              `json:"format"`
+	Query  storage.SearchQuery `json:"query"`
+}
+
+// SearchExportHandlers provides HTTP handlers for asynchronous search
+// exports. Exports are processed by the worker, which uploads the result set
+// to object storage; completed exports are downloaded through a pre-signed
+// URL, or through DownloadExport where the backend cannot pre-sign.
+type SearchExportHandlers struct {
+	pg        storage.PostgresStore
+	nats      streaming.NATSStreamer
+	s3        storage.ObjectStorage
+	urlExpiry time.Duration
+	retention time.Duration
+
+	stamps
+}
+
+// NewSearchExportHandlers creates the export handlers. urlExpiry is the
+// lifetime of generated download URLs and retention is how long export
+// objects are kept before cleanup.
+func NewSearchExportHandlers(pg storage.PostgresStore, nats streaming.NATSStreamer, s3 storage.ObjectStorage, urlExpiry, retention time.Duration) *SearchExportHandlers {
+	return &SearchExportHandlers{pg: pg, nats: nats, s3: s3, urlExpiry: urlExpiry, retention: retention}
+}
+
+// CreateExport handles POST /api/v1/analysis/{job_id}/exports.
+func (h *SearchExportHandlers) CreateExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		var req searchExportCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		if req.Format == "" {
+			req.Format = "csv"
+		}
+		if req.Format != "csv" && req.Format != "json" {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "format must be one of csv, json")
+			return
+		}
+		if req.Query.Query == "" {
+			req.Query.Query = "*"
+		}
+
+		job, err := h.pg.GetJob(r.Context(), tid, jobID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+		if job.Status != domain.JobStatusComplete {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+			return
+		}
+
+		query, err := json.Marshal(req.Query)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid query")
+			return
+		}
+
+		export := &domain.SearchExport{
+			ID:        h.newID(),
+			TenantID:  tid,
+			JobID:     jobID,
+			UserID:    middleware.GetUserID(r.Context()),
+			Status:    domain.ExportStatusQueued,
+			Format:    req.Format,
+			Query:     query,
+			ExpiresAt: h.now().Add(h.retention),
+		}
+
+		if err := h.pg.CreateSearchExport(r.Context(), export); err != nil {
+			slog.Error("failed to create search export", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create export")
+			return
+		}
+
+		if err := h.nats.PublishExportSubmit(r.Context(), tenantID, *export); err != nil {
+			errMsg := "failed to queue export: " + err.Error()
+			if updateErr := h.pg.UpdateSearchExportStatus(r.Context(), tid, export.ID, domain.ExportStatusFailed, &errMsg); updateErr != nil {
+				slog.Error("failed to update export status after NATS publish failure",
+					"export_id", export.ID, "tenant_id", tenantID, "error", updateErr)
+			}
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to queue export")
+			return
+		}
+
+		api.JSON(w, http.StatusAccepted, export)
+	})
+}
+
+// ListExports handles GET /api/v1/exports.
+func (h *SearchExportHandlers) ListExports() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		exports, err := h.pg.ListSearchExports(r.Context(), tid, maxListedExports)
+		if err != nil {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list exports")
+			return
+		}
+		if exports == nil {
+			exports = []domain.SearchExport{}
+		}
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"exports": exports,
+		})
+	})
+}
+
+// GetExport handles GET /api/v1/exports/{export_id}. Completed exports
+// include a download URL; pre-signed URLs are valid for the configured
+// expiry.
+func (h *SearchExportHandlers) GetExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+
+		exportID, ok := pathID(w, r, "export_id")
+		if !ok {
+			return
+		}
+
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		export, err := h.pg.GetSearchExport(r.Context(), tid, exportID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "export not found")
+			} else {
+				slog.Error("failed to retrieve export", "export_id", exportID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve export")
+			}
+			return
+		}
+
+		if err := setDownloadURL(r, h.s3, export, h.urlExpiry, "/api/v1/exports/"+exportID.String()+"/download"); err != nil {
+			slog.Error("failed to presign export URL", "export_id", exportID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to generate download URL")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, export)
+	})
+}
+
+// DownloadExport handles GET /api/v1/exports/{export_id}/download. It is the
+// download URL of completed exports on storage backends that cannot
+// pre-sign URLs.
+func (h *SearchExportHandlers) DownloadExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		exportID, ok := pathID(w, r, "export_id")
+		if !ok {
+			return
+		}
+
+		export, err := h.pg.GetSearchExport(r.Context(), tid, exportID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "export not found")
+			} else {
+				slog.Error("failed to retrieve export", "export_id", exportID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve export")
+			}
+			return
+		}
+
+		serveExportObject(w, r, h.s3, export)
+	})
+}
+
+// ExportProvenance handles GET /api/v1/exports/{export_id}/provenance. It
+// returns the full provenance of a completed export: the query, the
+// analyses it read and when they completed, the parser version, who
+// generated it and when, and the SHA-256 of the stored artifact.
+func (h *SearchExportHandlers) ExportProvenance() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		export, ok := h.lookup(w, r)
+		if !ok {
+			return
+		}
+		if export.Provenance == nil {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "export has no provenance until it is complete")
+			return
+		}
+		api.JSON(w, http.StatusOK, export.Provenance)
+	})
+}
+
+// RegenerateExport handles POST /api/v1/exports/{export_id}/regenerate. It
+// queues a new export replaying the provenance of a completed one; while
+// the analyses it read are unchanged the new artifact is byte-identical,
+// so its content hash matches the original's. Tenant bundles are not
+// reproducible and cannot be regenerated.
+func (h *SearchExportHandlers) RegenerateExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+
+		source, ok := h.lookup(w, r)
+		if !ok {
+			return
+		}
+		if source.Format == domain.ExportFormatTenantBundle {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "tenant exports cannot be regenerated")
+			return
+		}
+		if source.Provenance == nil {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "only completed exports with provenance can be regenerated")
+			return
+		}
+
+		export := &domain.SearchExport{
+			ID:              h.newID(),
+			TenantID:        source.TenantID,
+			JobID:           source.JobID,
+			UserID:          middleware.GetUserID(r.Context()),
+			Status:          domain.ExportStatusQueued,
+			Format:          source.Format,
+			Query:           source.Query,
+			ExpiresAt:       h.now().Add(h.retention),
+			RegeneratedFrom: &source.ID,
+		}
+
+		if err := h.pg.CreateSearchExport(r.Context(), export); err != nil {
+			slog.Error("failed to create regenerated export", "source_export_id", source.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create export")
+			return
+		}
+
+		if err := h.nats.PublishExportSubmit(r.Context(), tenantID, *export); err != nil {
+			errMsg := "failed to queue export: " + err.Error()
+			if updateErr := h.pg.UpdateSearchExportStatus(r.Context(), export.TenantID, export.ID, domain.ExportStatusFailed, &errMsg); updateErr != nil {
+				slog.Error("failed to update export status after NATS publish failure",
+					"export_id", export.ID, "tenant_id", tenantID, "error", updateErr)
+			}
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to queue export")
+			return
+		}
+
+		api.JSON(w, http.StatusAccepted, export)
+	})
+}
+
+// lookup loads the export named by the export_id path variable, writing
+// the error response when there is none.
+func (h *SearchExportHandlers) lookup(w http.ResponseWriter, r *http.Request) (*domain.SearchExport, bool) {
+	exportID, ok := pathID(w, r, "export_id")
+	if !ok {
+		return nil, false
+	}
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	export, err := h.pg.GetSearchExport(r.Context(), tid, exportID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "export not found")
+		} else {
+			slog.Error("failed to retrieve export", "export_id", exportID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve export")
+		}
+		return nil, false
+	}
+	return export, true
+}
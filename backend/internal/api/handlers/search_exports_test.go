@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+const (
+	testExportURLExpiry = 15 * time.Minute
+	testExportRetention = 7 * 24 * time.Hour
+)
+
+func newTestSearchExportHandlers(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer, s3 *testutil.MockObjectStorage) *SearchExportHandlers {
+	return NewSearchExportHandlers(pg, ns, s3, testExportURLExpiry, testExportRetention)
+}
+
+func TestSearchExportHandlers_CreateExport(t *testing.T) {
+	completeJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	parsingJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusParsing}
+
+	tests := []struct {
+		name       string
+		tenantID   string
+		jobID      string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "queues export and returns 202",
+			tenantID: fixedTenantID.String(),
+			jobID:    fixedJobID.String(),
+			body:     `{"format":"json","query":{"query":"status:error","log_types":["API"]}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					var q storage.SearchQuery
+					if err := json.Unmarshal(e.Query, &q); err != nil {
+						return false
+					}
+					return e.TenantID == fixedTenantID && e.JobID == fixedJobID &&
+						e.UserID == "test-user" && e.Format == "json" &&
+						e.Status == domain.ExportStatusQueued &&
+						q.Query == "status:error" && len(q.LogTypes) == 1 &&
+						e.ExpiresAt.After(time.Now().Add(testExportRetention-time.Minute))
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.SearchExport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, domain.ExportStatusQueued, resp.Status)
+				assert.Empty(t, resp.DownloadURL)
+			},
+		},
+		{
+			name:     "defaults to csv and match-all query",
+			tenantID: fixedTenantID.String(),
+			jobID:    fixedJobID.String(),
+			body:     `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					var q storage.SearchQuery
+					return json.Unmarshal(e.Query, &q) == nil && q.Query == "*" && e.Format == "csv"
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid format returns 400",
+			tenantID:   fixedTenantID.String(),
+			jobID:      fixedJobID.String(),
+			body:       `{"format":"xlsx"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, "format must be one of")
+			},
+		},
+		{
+			name:       "invalid JSON returns 400",
+			tenantID:   fixedTenantID.String(),
+			jobID:      fixedJobID.String(),
+			body:       `{`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing tenant returns 401",
+			jobID:      fixedJobID.String(),
+			body:       `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid job_id returns 400",
+			tenantID:   fixedTenantID.String(),
+			jobID:      "not-a-uuid",
+			body:       `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "job not found returns 404",
+			tenantID: fixedTenantID.String(),
+			jobID:    fixedJobID.String(),
+			body:     `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:     "job not complete returns 409",
+			tenantID: fixedTenantID.String(),
+			jobID:    fixedJobID.String(),
+			body:     `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsingJob, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:     "publish failure marks export failed and returns 500",
+			tenantID: fixedTenantID.String(),
+			jobID:    fixedJobID.String(),
+			body:     `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.AnythingOfType("*domain.SearchExport")).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(errors.New("nats down"))
+				pg.On("UpdateSearchExportStatus", mock.Anything, fixedTenantID, mock.AnythingOfType("uuid.UUID"), domain.ExportStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+			},
+			wantStatus: http.StatusInternalServerError,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, "failed to queue export")
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ns := new(testutil.MockNATSStreamer)
+			s3 := new(testutil.MockObjectStorage)
+			tc.setupMocks(pg, ns)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis/"+tc.jobID+"/exports", bytes.NewBufferString(tc.body))
+			if tc.tenantID != "" {
+				req = injectAuth(req, tc.tenantID)
+			}
+			req = mux.SetURLVars(req, map[string]string{"job_id": tc.jobID})
+
+			w := httptest.NewRecorder()
+			newTestSearchExportHandlers(pg, ns, s3).CreateExport().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchExportHandlers_GetExport(t *testing.T) {
+	exportID := uuid.MustParse("00000000-0000-0000-0000-000000000010")
+	key := "tenants/" + fixedTenantID.String() + "/exports/" + exportID.String() + ".csv.gz"
+
+	completeExport := func() *domain.SearchExport {
+		return &domain.SearchExport{
+			ID: exportID, TenantID: fixedTenantID, JobID: fixedJobID,
+			Status: domain.ExportStatusComplete, Format: "csv", RowCount: 42, S3Key: &key,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		exportID   string
+		setupMocks func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "complete export includes pre-signed URL",
+			exportID: exportID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(completeExport(), nil)
+				s3.On("PresignGetURL", mock.Anything, key, testExportURLExpiry).Return("https://s3.example/signed", nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, "https://s3.example/signed", resp["download_url"])
+				assert.NotEmpty(t, resp["url_expires_at"])
+				assert.NotContains(t, resp, "s3_key", "object key must not be exposed")
+			},
+		},
+		{
+			name:     "complete export carries its provenance summary",
+			exportID: exportID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				export := completeExport()
+				export.Provenance = &domain.ArtifactProvenance{
+					Format: "csv", Query: json.RawMessage(`{"query":"*"}`), ParserVersion: "2",
+					Analyses:    []domain.ProvenanceAnalysis{{JobID: fixedJobID}},
+					GeneratedBy: "test-user", ContentHash: "abc123",
+				}
+				export.ProvenanceSummary = export.Provenance.Summary()
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(export, nil)
+				s3.On("PresignGetURL", mock.Anything, key, testExportURLExpiry).Return("https://s3.example/signed", nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp struct {
+					Provenance map[string]interface{} `json:"provenance"`
+				}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, []interface{}{fixedJobID.String()}, resp.Provenance["analysis_ids"])
+				assert.Equal(t, "abc123", resp.Provenance["content_hash"])
+				assert.NotContains(t, resp.Provenance, "query", "the full record is served by the provenance endpoint")
+			},
+		},
+		{
+			name:     "running export has no URL",
+			exportID: exportID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(&domain.SearchExport{
+					ID: exportID, TenantID: fixedTenantID, Status: domain.ExportStatusRunning, ProgressPct: 40,
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.SearchExport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, 40, resp.ProgressPct)
+				assert.Empty(t, resp.DownloadURL)
+			},
+		},
+		{
+			name:     "presign failure returns 500",
+			exportID: exportID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(completeExport(), nil)
+				s3.On("PresignGetURL", mock.Anything, key, testExportURLExpiry).Return("", errors.New("no credentials"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:     "backend without presign links to the API download route",
+			exportID: exportID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(completeExport(), nil)
+				s3.On("PresignGetURL", mock.Anything, key, testExportURLExpiry).Return("", storage.ErrPresignUnsupported)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, "/api/v1/exports/"+exportID.String()+"/download", resp["download_url"])
+				assert.Nil(t, resp["url_expires_at"])
+			},
+		},
+		{
+			name:     "unknown export returns 404",
+			exportID: exportID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(nil, fmt.Errorf("postgres: search export not found: %s", exportID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid export_id returns 400",
+			exportID:   "nope",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ns := new(testutil.MockNATSStreamer)
+			s3 := new(testutil.MockObjectStorage)
+			tc.setupMocks(pg, s3)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/exports/"+tc.exportID, nil)
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"export_id": tc.exportID})
+
+			w := httptest.NewRecorder()
+			newTestSearchExportHandlers(pg, ns, s3).GetExport().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			s3.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchExportHandlers_DownloadExport(t *testing.T) {
+	exportID := uuid.MustParse("00000000-0000-0000-0000-000000000010")
+	key := "tenants/" + fixedTenantID.String() + "/exports/" + exportID.String() + ".json"
+
+	tests := []struct {
+		name       string
+		setupMocks func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "streams the export object",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(&domain.SearchExport{
+					ID: exportID, TenantID: fixedTenantID, Status: domain.ExportStatusComplete, S3Key: &key,
+				}, nil)
+				s3.On("Download", mock.Anything, key).Return(io.NopCloser(strings.NewReader(`[{"line":1}]`)), nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, `[{"line":1}]`, w.Body.String())
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Header().Get("Content-Disposition"), exportID.String()+".json")
+			},
+		},
+		{
+			name: "running export returns 409",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(&domain.SearchExport{
+					ID: exportID, TenantID: fixedTenantID, Status: domain.ExportStatusRunning,
+				}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "storage failure returns 500",
+			setupMocks: func(pg *testutil.MockPostgresStore, s3 *testutil.MockObjectStorage) {
+				pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(&domain.SearchExport{
+					ID: exportID, TenantID: fixedTenantID, Status: domain.ExportStatusComplete, S3Key: &key,
+				}, nil)
+				s3.On("Download", mock.Anything, key).Return(nil, errors.New("disk gone"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			s3 := new(testutil.MockObjectStorage)
+			tc.setupMocks(pg, s3)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/exports/"+exportID.String()+"/download", nil)
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"export_id": exportID.String()})
+
+			w := httptest.NewRecorder()
+			newTestSearchExportHandlers(pg, nil, s3).DownloadExport().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			s3.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchExportHandlers_ListExports(t *testing.T) {
+	t.Run("returns tenant exports", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListSearchExports", mock.Anything, fixedTenantID, maxListedExports).Return([]domain.SearchExport{
+			{ID: uuid.New(), TenantID: fixedTenantID, Status: domain.ExportStatusComplete},
+			{ID: uuid.New(), TenantID: fixedTenantID, Status: domain.ExportStatusExpired},
+		}, nil)
+
+		req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil), fixedTenantID.String())
+		w := httptest.NewRecorder()
+		newTestSearchExportHandlers(pg, nil, nil).ListExports().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Exports []domain.SearchExport `json:"exports"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Len(t, resp.Exports, 2)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("empty list is an array", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListSearchExports", mock.Anything, fixedTenantID, maxListedExports).Return(nil, nil)
+
+		req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil), fixedTenantID.String())
+		w := httptest.NewRecorder()
+		newTestSearchExportHandlers(pg, nil, nil).ListExports().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"exports":[]}`, w.Body.String())
+	})
+
+	t.Run("store error returns 500", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListSearchExports", mock.Anything, fixedTenantID, maxListedExports).Return(nil, errors.New("db down"))
+
+		req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/exports", nil), fixedTenantID.String())
+		w := httptest.NewRecorder()
+		newTestSearchExportHandlers(pg, nil, nil).ListExports().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestSearchExportHandlers_ExportProvenance(t *testing.T) {
+	exportID := uuid.MustParse("00000000-0000-0000-0000-000000000010")
+	completed := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	prov := &domain.ArtifactProvenance{
+		Format:        "csv",
+		Query:         json.RawMessage(`{"query":"status:error"}`),
+		Analyses:      []domain.ProvenanceAnalysis{{JobID: fixedJobID, CompletedAt: &completed}},
+		ParserVersion: "2",
+		GeneratedBy:   "test-user",
+		GeneratedAt:   time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+		ContentHash:   strings.Repeat("ab", 32),
+	}
+
+	tests := []struct {
+		name       string
+		export     *domain.SearchExport
+		err        error
+		wantStatus int
+	}{
+		{
+			name:       "complete export returns its provenance",
+			export:     &domain.SearchExport{ID: exportID, TenantID: fixedTenantID, Status: domain.ExportStatusComplete, Provenance: prov},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "running export has none yet",
+			export:     &domain.SearchExport{ID: exportID, TenantID: fixedTenantID, Status: domain.ExportStatusRunning},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "unknown export returns 404",
+			err:        fmt.Errorf("postgres: search export not found: %s", exportID),
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			pg.On("GetSearchExport", mock.Anything, fixedTenantID, exportID).Return(tc.export, tc.err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/exports/"+exportID.String()+"/provenance", nil)
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"export_id": exportID.String()})
+
+			w := httptest.NewRecorder()
+			newTestSearchExportHandlers(pg, nil, nil).ExportProvenance().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusOK {
+				var got domain.ArtifactProvenance
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+				assert.Equal(t, *prov, got)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchExportHandlers_RegenerateExport(t *testing.T) {
+	sourceID := uuid.MustParse("00000000-0000-0000-0000-000000000010")
+	query := json.RawMessage(`{"query":"status:error"}`)
+	source := func(format string, prov *domain.ArtifactProvenance) *domain.SearchExport {
+		return &domain.SearchExport{
+			ID: sourceID, TenantID: fixedTenantID, JobID: fixedJobID, UserID: "someone-else",
+			Status: domain.ExportStatusComplete, Format: format, Query: query, Provenance: prov,
+		}
+	}
+	prov := &domain.ArtifactProvenance{Format: "csv", GeneratedBy: "someone-else"}
+
+	tests := []struct {
+		name       string
+		source     *domain.SearchExport
+		setupMocks func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer)
+		wantStatus int
+	}{
+		{
+			name:   "queues a regeneration of the source",
+			source: source("csv", prov),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					return e.ID != sourceID && e.TenantID == fixedTenantID && e.JobID == fixedJobID &&
+						e.UserID == "test-user" && e.Format == "csv" && string(e.Query) == string(query) &&
+						e.Status == domain.ExportStatusQueued &&
+						e.RegeneratedFrom != nil && *e.RegeneratedFrom == sourceID
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, fixedTenantID.String(), mock.AnythingOfType("domain.SearchExport")).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "incomplete source returns 409",
+			source:     source("csv", nil),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "tenant bundle returns 400",
+			source:     source(domain.ExportFormatTenantBundle, prov),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "publish failure marks the export failed",
+			source: source("json", prov),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("CreateSearchExport", mock.Anything, mock.AnythingOfType("*domain.SearchExport")).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("nats down"))
+				pg.On("UpdateSearchExportStatus", mock.Anything, fixedTenantID, mock.Anything, domain.ExportStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ns := new(testutil.MockNATSStreamer)
+			pg.On("GetSearchExport", mock.Anything, fixedTenantID, sourceID).Return(tc.source, nil)
+			if tc.setupMocks != nil {
+				tc.setupMocks(pg, ns)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/exports/"+sourceID.String()+"/regenerate", nil)
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"export_id": sourceID.String()})
+
+			w := httptest.NewRecorder()
+			newTestSearchExportHandlers(pg, ns, nil).RegenerateExport().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusAccepted {
+				var resp map[string]interface{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, sourceID.String(), resp["regenerated_from"])
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -17,6 +18,7 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
 )
@@ -164,6 +166,51 @@ func TestSearchLogsHandler_GET_Success(t *testing.T) {
 	mockCH.AssertExpectations(t)
 }
 
+func TestSearchLogsHandler_QueryInterpretation(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(&storage.SearchResult{Entries: []domain.LogEntry{}, TotalCount: 0}, nil)
+	setupCHFacets(mockCH, tenantID, jobID.String())
+
+	q := url.QueryEscape(`timeout "connection refused" type:API`)
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?q="+q, nil, tenantID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.QueryInterpretation)
+	assert.Equal(t, []search.InterpretedTerm{
+		{Term: "timeout", Match: search.MatchWord},
+		{Term: "connection refused", Match: search.MatchSubstring},
+	}, resp.QueryInterpretation.Terms)
+	assert.Len(t, resp.QueryInterpretation.Notes, 2)
+}
+
+func TestSearchLogsHandler_TooBroad(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(nil, fmt.Errorf("clickhouse: search count: %w: limit for rows exceeded", storage.ErrSearchTooBroad))
+
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?q=error", nil, tenantID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var errResp api.ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&errResp))
+	assert.Equal(t, api.ErrCodeQueryTooBroad, errResp.Code)
+	assert.Equal(t, "query too broad, please add filters", errResp.Message)
+	mockCH.AssertNotCalled(t, "GetFacets", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestSearchLogsHandler_GET_WithPagination(t *testing.T) {
 	h, mockCH, _ := setupSearchLogsHandler()
 	jobID := uuid.New()
@@ -215,6 +262,48 @@ func TestSearchLogsHandler_GET_WithSort(t *testing.T) {
 	mockCH.AssertExpectations(t)
 }
 
+func TestSearchLogsHandler_SecondarySort(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.MatchedBy(func(q storage.SearchQuery) bool {
+		return q.SortBy == "queue" && q.SortOrder == "asc" && q.SecondarySortBy == "form" && q.SecondarySortOrder == "desc"
+	})).Return(&storage.SearchResult{Entries: []domain.LogEntry{}, TotalCount: 0}, nil)
+	setupCHFacets(mockCH, tenantID, jobID.String())
+
+	params := url.Values{"sort_by": {"queue"}, "sort_order": {"asc"}, "secondary_sort_by": {"form"}}
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?"+params.Encode(), nil, tenantID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []domain.SortKey{
+		{Field: "queue", Order: "asc"},
+		{Field: "form", Order: "desc"},
+		{Field: "timestamp", Order: "asc"},
+		{Field: "line_number", Order: "asc"},
+		{Field: "entry_id", Order: "asc"},
+	}, resp.Sort)
+	mockCH.AssertExpectations(t)
+}
+
+func TestSearchLogsHandler_InvalidSecondarySort(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+
+	params := url.Values{"sort_by": {"user"}, "secondary_sort_by": {"raw_text"}}
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?"+params.Encode(), nil, "test-tenant")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid secondary_sort_by")
+	mockCH.AssertNotCalled(t, "SearchEntries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestSearchLogsHandler_GET_WithTimeRange(t *testing.T) {
 	h, mockCH, _ := setupSearchLogsHandler()
 	jobID := uuid.New()
@@ -237,6 +326,27 @@ func TestSearchLogsHandler_GET_WithTimeRange(t *testing.T) {
 	mockCH.AssertExpectations(t)
 }
 
+func TestSearchLogsHandler_GET_IncludeNoise(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.MatchedBy(func(q storage.SearchQuery) bool {
+		return q.IncludeNoise
+	})).Return(&storage.SearchResult{Entries: []domain.LogEntry{{EntryID: "e1", IsNoise: true}}, TotalCount: 1}, nil)
+
+	setupCHFacets(mockCH, tenantID, jobID.String())
+
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?q=test&include_noise=true", nil, tenantID)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"is_noise":true`)
+	mockCH.AssertExpectations(t)
+}
+
 func TestSearchLogsHandler_POST_Success(t *testing.T) {
 	h, mockCH, _ := setupSearchLogsHandler()
 	jobID := uuid.New()
@@ -291,3 +401,145 @@ func TestSearchLogsHandler_WildcardQuery(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	mockCH.AssertExpectations(t)
 }
+
+func TestSearchLogsHandler_Suggest(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(&storage.SearchResult{Entries: []domain.LogEntry{}, TotalCount: 2000}, nil)
+	mockCH.On("GetFacets", mock.Anything, tenantID, jobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(map[string][]storage.FacetValue{"user": {{Value: "IntegrationSvc", Count: 1800}}}, nil)
+	mockCH.On("GetRefinementStats", mock.Anything, tenantID, jobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(&storage.RefinementStats{Failed: 40, SlowerThan: []storage.DurationCount{{ThresholdMS: 5000, Count: 312}}}, nil)
+
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?q=type:API&suggest=true", nil, tenantID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Suggestions, 3)
+	assert.Equal(t, "duration:>5000", resp.Suggestions[0].KQL)
+	assert.EqualValues(t, 312, resp.Suggestions[0].PredictedCount)
+	assert.Equal(t, "user:IntegrationSvc", resp.Suggestions[1].KQL)
+	assert.Equal(t, "type:API user:IntegrationSvc", resp.Suggestions[1].Query)
+	assert.Equal(t, "90% of these results are user:IntegrationSvc", resp.Suggestions[1].Reason)
+	assert.Equal(t, "status:false", resp.Suggestions[2].KQL)
+	mockCH.AssertExpectations(t)
+}
+
+func TestSearchLogsHandler_SuggestSkipped(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		total int64
+	}{
+		{name: "not asked for", path: "?q=type:API", total: 2000},
+		{name: "few results", path: "?q=type:API&suggest=true", total: 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mockCH, _ := setupSearchLogsHandler()
+			jobID := uuid.New()
+			tenantID := "test-tenant"
+			mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+				Return(&storage.SearchResult{Entries: []domain.LogEntry{}, TotalCount: tt.total}, nil)
+			setupCHFacets(mockCH, tenantID, jobID.String())
+
+			req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search"+tt.path, nil, tenantID)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.NotContains(t, w.Body.String(), "suggestions")
+			mockCH.AssertNotCalled(t, "GetRefinementStats", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSearchLogsHandler_SortByComputedColumn(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.MatchedBy(func(q storage.SearchQuery) bool {
+		return q.SortBy == "total_ms" && q.SortOrder == "asc" &&
+			len(q.Computed) == 2 && q.Computed[0].Name == "total_ms" && q.Computed[1].Name == "is_slow"
+	})).Return(&storage.SearchResult{
+		Entries:    []domain.LogEntry{{EntryID: "e1"}, {EntryID: "e2"}},
+		TotalCount: 2,
+		Computed: []map[string]any{
+			{"total_ms": float64(120), "is_slow": false},
+			{"total_ms": float64(2600), "is_slow": true},
+		},
+	}, nil)
+	setupCHFacets(mockCH, tenantID, jobID.String())
+
+	params := url.Values{
+		"q":          {"type:API"},
+		"sort_by":    {"total_ms"},
+		"sort_order": {"asc"},
+		"computed":   {"total_ms:duration_ms + queue_time_ms", "is_slow: duration_ms > 2000"},
+	}
+	req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?"+params.Encode(), nil, tenantID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp SearchResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, map[string]any{"total_ms": float64(120), "is_slow": false}, resp.Results[0].Computed)
+	assert.Equal(t, map[string]any{"total_ms": float64(2600), "is_slow": true}, resp.Results[1].Computed)
+	mockCH.AssertExpectations(t)
+}
+
+func TestSearchLogsHandler_POST_ComputedColumns(t *testing.T) {
+	h, mockCH, _ := setupSearchLogsHandler()
+	jobID := uuid.New()
+	tenantID := "test-tenant"
+
+	mockCH.On("SearchEntries", mock.Anything, tenantID, jobID.String(), mock.MatchedBy(func(q storage.SearchQuery) bool {
+		return q.SortBy == "prefix" && len(q.Computed) == 1 && q.Computed[0].SQL != ""
+	})).Return(&storage.SearchResult{Entries: []domain.LogEntry{}, TotalCount: 0}, nil)
+	setupCHFacets(mockCH, tenantID, jobID.String())
+
+	body := `{"query":"*","sort_by":"prefix","computed":[{"name":"prefix","expr":"substr(form, 1, position(form, ':') - 1)"}]}`
+	req := makeJobSearchRequest(http.MethodPost, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search", []byte(body), tenantID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockCH.AssertExpectations(t)
+}
+
+func TestSearchLogsHandler_InvalidComputedColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		computed []string
+		want     string
+	}{
+		{name: "function outside the whitelist", computed: []string{"x:sleep(3)"}, want: `unknown function \"sleep\"`},
+		{name: "tenant column", computed: []string{"x:tenant_id"}, want: `unknown field \"tenant_id\"`},
+		{name: "missing name", computed: []string{"duration_ms + 1"}, want: "invalid computed column name"},
+		{name: "too many", computed: []string{"a:1", "b:1", "c:1", "d:1", "e:1", "f:1"}, want: "at most 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mockCH, _ := setupSearchLogsHandler()
+			jobID := uuid.New()
+
+			params := url.Values{"computed": tt.computed}
+			req := makeJobSearchRequest(http.MethodGet, jobID.String(), "/api/v1/analysis/"+jobID.String()+"/search?"+params.Encode(), nil, "test-tenant")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.want)
+			mockCH.AssertNotCalled(t, "SearchEntries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// section describes one dashboard section of a completed job: where it is
+// cached and how it is rebuilt when the cache misses. T is the type the
+// section is computed as; a section the JAR wrote may be of another type.
+type section[T any] struct {
+	// name names the section in its ETag and error messages.
+	name string
+	// cacheKey is appended to the job's dashboard cache key.
+	cacheKey string
+	// decodeJAR decodes the section as the JAR wrote it, reporting whether
+	// the cached value was one. Nil when the JAR has no such section.
+	decodeJAR func(cached string) (any, bool)
+	// compute picks the section out of those computed from the cached
+	// dashboard. A nil result means there was nothing to compute it from.
+	compute func(*domain.ParseResult) *T
+	// empty stands in for a section that could not be computed; without
+	// it a zero T does.
+	empty func() *T
+}
+
+// jarSection decodes a cached section the JAR wrote as a J.
+func jarSection[J any](cached string) (any, bool) {
+	if !isJARParsedCache(cached) {
+		return nil, false
+	}
+	var data J
+	if err := json.Unmarshal([]byte(cached), &data); err != nil {
+		return nil, false
+	}
+	return &data, true
+}
+
+// load returns the cached section, or computes it from the cached
+// dashboard and caches it. It fails only when the dashboard is missing too.
+// Identical loads missing the cache at once share one computation.
+func (s section[T]) load(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, error) {
+	data, _, err := s.loadCached(ctx, redis, tenantID, jobID)
+	return data, err
+}
+
+// loadCached is load, also reporting whether the section was read from the
+// cache.
+func (s section[T]) loadCached(ctx context.Context, redis storage.RedisCache, tenantID, jobID string) (any, bool, error) {
+	cacheKey := redis.TenantKey(tenantID, "dashboard", jobID) + ":" + s.cacheKey
+	cached, err := redis.Get(ctx, cacheKey)
+	if err == nil && cached != "" {
+		if s.decodeJAR != nil {
+			if data, ok := s.decodeJAR(cached); ok {
+				return data, true, nil
+			}
+		}
+		var data T
+		if err := json.Unmarshal([]byte(cached), &data); err == nil {
+			return &data, true, nil
+		}
+	}
+
+	data, err := coalesce(ctx, cacheKey, func(ctx context.Context) (any, error) {
+		dashboard, err := getDashboardFromCache(ctx, redis, tenantID, jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		data := s.compute(worker.ComputeEnhancedSections(dashboard))
+		if data == nil {
+			if s.empty != nil {
+				return s.empty(), nil
+			}
+			return new(T), nil
+		}
+
+		_ = redis.Set(ctx, cacheKey, data, sectionCacheTTL)
+		return data, nil
+	})
+	return data, false, err
+}
+
+// sectionHandler serves one section of a completed job. The section
+// handlers differ only in their section, so each embeds one of these.
+type sectionHandler[T any] struct {
+	pg    storage.PostgresStore
+	ch    storage.ClickHouseStore
+	redis storage.RedisCache
+
+	section section[T]
+	// decorate adjusts the loaded section for the job before it is
+	// written; optional.
+	decorate func(ctx context.Context, job *domain.AnalysisJob, data any)
+	// overlay sets the overlays of the job's incident group on the loaded
+	// section, for requests with ?include_group=true; nil when the section
+	// has none.
+	overlay func(data any, overlays []domain.SourceOverlay)
+}
+
+func newSectionHandler[T any](pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache, s section[T]) *sectionHandler[T] {
+	return &sectionHandler[T]{pg: pg, ch: ch, redis: redis, section: s}
+}
+
+func (h *sectionHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	job, jobID, ok := h.completedJob(w, r)
+	if !ok {
+		return
+	}
+	tenantID := middleware.GetTenantID(r.Context())
+
+	format, ok := tableFormat(r)
+	if !ok {
+		badTableFormat(w)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	// Overlays change with the other analyses of the group, which the
+	// job's ETag does not cover.
+	withGroup := h.overlay != nil && format == "" && includeGroup(r)
+	etag := sectionETag(job, sectionVariant(r, h.section.name, format))
+	if !withGroup && sectionNotModified(w, r, etag) {
+		return
+	}
+
+	data, err := h.section.load(r.Context(), h.redis, tenantID, jobID.String())
+	if err != nil {
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, h.section.name+" data not available")
+		return
+	}
+	if h.decorate != nil {
+		h.decorate(r.Context(), job, data)
+	}
+	if withGroup {
+		overlays, err := groupOverlays(r.Context(), h.pg, h.ch, job)
+		if err != nil {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to load incident group overlays")
+			return
+		}
+		h.overlay(data, overlays)
+	}
+
+	if format != "" {
+		writeSectionTable(w, r, h.pg, job, etag, h.section.name, data, format, true)
+		return
+	}
+	writeSection(w, etag, data, !withGroup)
+}
+
+// completedJob returns the job the request names and its ID, answering the
+// request when there is none or the job is not complete.
+func (h *sectionHandler[T]) completedJob(w http.ResponseWriter, r *http.Request) (*domain.AnalysisJob, uuid.UUID, bool) {
+	if middleware.GetTenantID(r.Context()) == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return nil, uuid.Nil, false
+	}
+
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return nil, uuid.Nil, false
+	}
+
+	if job.Status != domain.JobStatusComplete {
+		sectionNotComplete(w)
+		return nil, uuid.Nil, false
+	}
+	return job, jobID, true
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// staleSectionTTL is how long the last good copy of a section read
+	// from ClickHouse is kept to serve while ClickHouse is down, well past
+	// sectionCacheTTL.
+	staleSectionTTL = 30 * 24 * time.Hour
+	// staleSuffix is appended to a section's cache key to name its last
+	// good copy. It stays under the job's dashboard prefix, so the copy is
+	// purged with the job.
+	staleSuffix = ":stale"
+
+	// degradedReason is the machine-readable reason of degraded responses
+	// and of the 503 answered when there is nothing to degrade to.
+	degradedReason = "clickhouse_unavailable"
+	// unavailableRetryAfter is the Retry-After of that 503, in seconds.
+	unavailableRetryAfter = "30"
+)
+
+// errClickHouseUnavailable is returned by readLive when ClickHouse cannot
+// be reached and the section was never cached.
+var errClickHouseUnavailable = errors.New("clickhouse unavailable and no cached section")
+
+// staleCopy is the last good copy of a section and when it was read.
+type staleCopy[T any] struct {
+	CachedAt time.Time `json:"cached_at"`
+	Data     *T        `json:"data"`
+}
+
+// readLive returns the section cached under key, or reads it from
+// ClickHouse with query and caches it. Identical reads missing the cache
+// at once share one query.
+//
+// When ClickHouse cannot be reached the last good copy of the section is
+// returned instead, with the time it was read; the time is nil for live
+// data. Without a copy the error wraps errClickHouseUnavailable. Errors of
+// the query itself are returned as they are.
+func readLive[T any](ctx context.Context, redis storage.RedisCache, key string, query func(ctx context.Context) (*T, error)) (*T, *time.Time, error) {
+	if cached, err := redis.Get(ctx, key); err == nil && cached != "" {
+		var data T
+		if err := json.Unmarshal([]byte(cached), &data); err == nil {
+			return &data, nil, nil
+		}
+	}
+
+	data, err := coalesce(ctx, key, func(ctx context.Context) (*T, error) {
+		data, err := query(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := redis.Set(ctx, key, data, sectionCacheTTL); err != nil {
+			slog.Warn("failed to cache section", "key", key, "error", err)
+		}
+		_ = redis.Set(ctx, key+staleSuffix, staleCopy[T]{CachedAt: time.Now().UTC(), Data: data}, staleSectionTTL)
+		return data, nil
+	})
+	if err == nil || !storage.IsUnavailable(err) || ctx.Err() != nil {
+		return data, nil, err
+	}
+
+	cached, getErr := redis.Get(ctx, key+staleSuffix)
+	if getErr == nil && cached != "" {
+		var stale staleCopy[T]
+		if json.Unmarshal([]byte(cached), &stale) == nil && stale.Data != nil {
+			slog.Warn("clickhouse unavailable, serving cached section", "key", key, "cached_at", stale.CachedAt, "error", err)
+			return stale.Data, &stale.CachedAt, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %w", errClickHouseUnavailable, err)
+}
+
+// writeLiveSection writes a section returned by readLive: as writeSection
+// when it is live, else as writeDegraded.
+func writeLiveSection(w http.ResponseWriter, etag string, data any, cachedAt *time.Time) {
+	if cachedAt == nil {
+		writeSection(w, etag, data, true)
+		return
+	}
+	writeDegraded(w, data, *cachedAt)
+}
+
+// setDegradedHeaders marks a response as served from the copy of a section
+// read at cachedAt, for representations without room for the flag. The
+// response must not be cached: the live section replaces it once
+// ClickHouse is back.
+func setDegradedHeaders(w http.ResponseWriter, cachedAt time.Time) {
+	noStore(w)
+	w.Header().Set("X-Degraded", degradedReason)
+	w.Header().Set("X-Cached-At", cachedAt.UTC().Format(time.RFC3339))
+}
+
+// writeDegraded writes the copy of a section read at cachedAt, adding
+// "degraded", "cached_at" and "reason" to the section object.
+func writeDegraded(w http.ResponseWriter, data any, cachedAt time.Time) {
+	setDegradedHeaders(w, cachedAt)
+	body, err := json.Marshal(data)
+	if err != nil || len(body) < 2 || body[0] != '{' {
+		api.JSON(w, http.StatusOK, data)
+		return
+	}
+	meta, _ := json.Marshal(struct {
+		Degraded bool      `json:"degraded"`
+		CachedAt time.Time `json:"cached_at"`
+		Reason   string    `json:"reason"`
+	}{true, cachedAt.UTC(), degradedReason})
+	if string(body) != "{}" {
+		meta = append(append(meta[:len(meta)-1], ','), body[1:]...)
+	}
+	api.JSON(w, http.StatusOK, json.RawMessage(meta))
+}
+
+// clickHouseUnavailable answers a section request when ClickHouse cannot
+// be reached and the section was never cached.
+func clickHouseUnavailable(w http.ResponseWriter, section string) {
+	noStore(w)
+	w.Header().Set("Retry-After", unavailableRetryAfter)
+	api.ErrorWithDetails(w, http.StatusServiceUnavailable, api.ErrCodeServiceUnavail,
+		section+" is unavailable while the analytics store is down",
+		map[string]string{"reason": degradedReason, "section": section})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// errClickHouseDown is what the store returns while ClickHouse refuses
+// connections.
+var errClickHouseDown = fmt.Errorf("clickhouse: query: %w",
+	&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+
+type liveSection struct {
+	Total int `json:"total"`
+}
+
+func TestReadLive(t *testing.T) {
+	ctx := context.Background()
+	const key = "tenant:t:dashboard:j:live"
+
+	t.Run("live read keeps a stale copy", func(t *testing.T) {
+		redis := new(testutil.MockRedisCache)
+		redis.On("Get", mock.Anything, key).Return("", errors.New("redis: nil"))
+		redis.On("Set", mock.Anything, key, &liveSection{Total: 3}, sectionCacheTTL).Return(nil)
+		redis.On("Set", mock.Anything, key+staleSuffix, mock.MatchedBy(func(c staleCopy[liveSection]) bool {
+			return c.Data.Total == 3 && !c.CachedAt.IsZero()
+		}), staleSectionTTL).Return(nil)
+
+		data, cachedAt, err := readLive(ctx, redis, key, func(context.Context) (*liveSection, error) {
+			return &liveSection{Total: 3}, nil
+		})
+		require.NoError(t, err)
+		assert.Nil(t, cachedAt)
+		assert.Equal(t, 3, data.Total)
+		redis.AssertExpectations(t)
+	})
+
+	t.Run("query errors are not degraded", func(t *testing.T) {
+		redis := new(testutil.MockRedisCache)
+		redis.On("Get", mock.Anything, key).Return("", errors.New("redis: nil"))
+		boom := errors.New("clickhouse: query: code: 60, message: unknown table")
+
+		_, _, err := readLive(ctx, redis, key, func(context.Context) (*liveSection, error) {
+			return nil, boom
+		})
+		assert.ErrorIs(t, err, boom)
+		assert.NotErrorIs(t, err, errClickHouseUnavailable)
+		redis.AssertNotCalled(t, "Get", mock.Anything, key+staleSuffix)
+	})
+
+	t.Run("unavailable without a copy", func(t *testing.T) {
+		redis := new(testutil.MockRedisCache)
+		redis.On("Get", mock.Anything, mock.Anything).Return("", errors.New("redis: nil"))
+
+		_, _, err := readLive(ctx, redis, key, func(context.Context) (*liveSection, error) {
+			return nil, errClickHouseDown
+		})
+		assert.ErrorIs(t, err, errClickHouseUnavailable)
+		assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	})
+}
+
+func TestWriteDegraded(t *testing.T) {
+	cachedAt := time.Date(2026, 2, 3, 11, 0, 0, 0, time.FixedZone("", 3600))
+
+	w := httptest.NewRecorder()
+	writeDegraded(w, &liveSection{Total: 3}, cachedAt)
+	assert.JSONEq(t, `{"degraded":true,"cached_at":"2026-02-03T10:00:00Z","reason":"clickhouse_unavailable","total":3}`, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "2026-02-03T10:00:00Z", w.Header().Get("X-Cached-At"))
+
+	w = httptest.NewRecorder()
+	writeDegraded(w, struct{}{}, cachedAt)
+	assert.JSONEq(t, `{"degraded":true,"cached_at":"2026-02-03T10:00:00Z","reason":"clickhouse_unavailable"}`, w.Body.String())
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// sectionCase describes one section handler for the common test matrix.
+type sectionCase struct {
+	name     string
+	path     string
+	cacheKey string
+	handler  func(storage.PostgresStore, storage.ClickHouseStore, storage.RedisCache) http.Handler
+	// cached is a section as the worker caches it, jar one as the JAR wrote it.
+	cached, jar any
+}
+
+func sectionCases() []sectionCase {
+	return []sectionCase{
+		{
+			name: "aggregates", path: "aggregates", cacheKey: "agg",
+			handler: func(pg storage.PostgresStore, ch storage.ClickHouseStore, r storage.RedisCache) http.Handler {
+				return NewAggregatesHandler(pg, ch, r)
+			},
+			cached: domain.AggregatesResponse{API: &domain.AggregateSection{Groups: []domain.AggregateGroup{{Name: "HPD:Help Desk", Count: 3}}}},
+			jar:    domain.JARAggregatesResponse{Source: "jar_parsed"},
+		},
+		{
+			name: "exceptions", path: "exceptions", cacheKey: "exc",
+			handler: func(pg storage.PostgresStore, ch storage.ClickHouseStore, r storage.RedisCache) http.Handler {
+				return NewExceptionsHandler(pg, ch, r)
+			},
+			cached: domain.ExceptionsResponse{Exceptions: []domain.ExceptionEntry{{ErrorCode: "ARERR 302", Count: 2}}, TotalCount: 2},
+			jar:    domain.JARExceptionsResponse{Source: "jar_parsed"},
+		},
+		{
+			name: "gaps", path: "gaps", cacheKey: "gaps",
+			handler: func(pg storage.PostgresStore, ch storage.ClickHouseStore, r storage.RedisCache) http.Handler {
+				return NewGapsHandler(pg, ch, r)
+			},
+			cached: domain.GapsResponse{Gaps: []domain.GapEntry{{DurationMS: 40000, BeforeLine: 11, AfterLine: 12}}},
+			jar:    domain.JARGapsResponse{Source: "jar_parsed"},
+		},
+		{
+			name: "threads", path: "threads", cacheKey: "threads",
+			handler: func(pg storage.PostgresStore, ch storage.ClickHouseStore, r storage.RedisCache) http.Handler {
+				return NewThreadsHandler(pg, ch, r)
+			},
+			cached: domain.ThreadStatsResponse{Threads: []domain.ThreadStatsEntry{{ThreadID: "0000000123", TotalCalls: 9}}, TotalThreads: 1},
+			jar:    domain.JARThreadStatsResponse{Source: "jar_parsed"},
+		},
+		{
+			name: "filters", path: "filters", cacheKey: "filters",
+			handler: func(pg storage.PostgresStore, ch storage.ClickHouseStore, r storage.RedisCache) http.Handler {
+				return NewFiltersHandler(pg, ch, r)
+			},
+			cached: domain.FilterComplexityResponse{MostExecuted: []domain.MostExecutedFilter{{Name: "HPD:Validate", Count: 4}}},
+			jar:    domain.JARFilterComplexityResponse{Source: "jar_parsed"},
+		},
+	}
+}
+
+// TestSectionHandlers runs every section handler through the same matrix.
+func TestSectionHandlers(t *testing.T) {
+	otherTenant := uuid.MustParse("00000000-0000-0000-0000-0000000000ff")
+	baseKey := "tenant:" + fixedTenantID.String() + ":dashboard:" + fixedJobID.String()
+	dashboardJSON, err := json.Marshal(sampleDashboardData())
+	require.NoError(t, err)
+
+	expectSection := func(m *handlerMocks) {
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completedJob(fixedTenantID, fixedJobID), nil)
+		m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+		// The threads section reports capacity only when the load can be
+		// read; the matrix leaves it out.
+		m.ch.On("GetQueueLoad", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+			Return(nil, errors.New("clickhouse unavailable")).Maybe()
+	}
+	cachedJSON := func(t *testing.T, v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		jobID      string
+		setupMocks func(t *testing.T, m *handlerMocks, sc sectionCase)
+		wantStatus int
+		checkBody  func(t *testing.T, sc sectionCase, body []byte)
+	}{
+		{
+			name: "cache hit",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				expectSection(m)
+				m.redis.On("Get", mock.Anything, baseKey+":"+sc.cacheKey).Return(cachedJSON(t, sc.cached), nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, sc sectionCase, body []byte) {
+				assert.JSONEq(t, cachedJSON(t, sc.cached), string(body))
+			},
+		},
+		{
+			name: "JAR cache hit",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				expectSection(m)
+				m.redis.On("Get", mock.Anything, baseKey+":"+sc.cacheKey).Return(cachedJSON(t, sc.jar), nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, sc sectionCase, body []byte) {
+				assert.JSONEq(t, cachedJSON(t, sc.jar), string(body))
+			},
+		},
+		{
+			name: "cache miss computes from the dashboard",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				expectSection(m)
+				m.redis.On("Get", mock.Anything, baseKey+":"+sc.cacheKey).Return("", errors.New("redis: nil"))
+				m.redis.On("Get", mock.Anything, baseKey).Return(string(dashboardJSON), nil)
+				m.redis.On("Set", mock.Anything, baseKey+":"+sc.cacheKey, mock.Anything, sectionCacheTTL).Return(nil).Maybe()
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, sc sectionCase, body []byte) {
+				assertGoldenJSON(t, "section_"+sc.name, body)
+			},
+		},
+		{
+			name: "cache and dashboard missing",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				expectSection(m)
+				m.redis.On("Get", mock.Anything, baseKey+":"+sc.cacheKey).Return("", errors.New("redis: nil"))
+				m.redis.On("Get", mock.Anything, baseKey).Return("", errors.New("redis: nil"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			checkBody: func(t *testing.T, sc sectionCase, body []byte) {
+				assert.Contains(t, string(body), sc.name+" data not available")
+			},
+		},
+		{
+			name: "job store error",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, errors.New("connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "not complete",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				job := completedJob(fixedTenantID, fixedJobID)
+				job.Status = domain.JobStatusAnalyzing
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "bad job id",
+			jobID:      "not-a-uuid",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "wrong tenant",
+			tenant: otherTenant.String(),
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {
+				m.pg.On("GetJob", mock.Anything, otherTenant, fixedJobID).Return(nil, pgx.ErrNoRows)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no tenant",
+			tenant:     "-",
+			setupMocks: func(t *testing.T, m *handlerMocks, sc sectionCase) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, sc := range sectionCases() {
+		for _, tc := range tests {
+			t.Run(sc.name+"/"+tc.name, func(t *testing.T) {
+				m := newHandlerMocks()
+				tc.setupMocks(t, m, sc)
+
+				tenant, jobID := fixedTenantID.String(), fixedJobID.String()
+				if tc.tenant == "-" {
+					tenant = ""
+				} else if tc.tenant != "" {
+					tenant = tc.tenant
+				}
+				if tc.jobID != "" {
+					jobID = tc.jobID
+				}
+				w := newTestRequest(http.MethodGet, "/api/v1/analysis/"+jobID+"/dashboard/"+sc.path).
+					tenant(tenant).
+					vars("job_id", jobID).
+					serve(sc.handler(m.pg, m.ch, m.redis))
+
+				assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+				if tc.checkBody != nil {
+					tc.checkBody(t, sc, w.Body.Bytes())
+				}
+				m.assertExpectations(t)
+			})
+		}
+	}
+}
+
+// TestJARFiltersSection_StripsTruncationMarkers covers filter sections cached
+// before the JAR's truncated names were resolved.
+func TestJARFiltersSection_StripsTruncationMarkers(t *testing.T) {
+	cached, err := json.Marshal(domain.JARFilterComplexityResponse{
+		LongestRunning: []domain.TopNEntry{{Identifier: "HPD:INC:SendNotification"}},
+		MostExecuted:   []domain.JARFilterMostExecuted{{FilterName: "HPD:INC:SendNotific`!"}, {FilterName: "CHG:Approve`!"}},
+		Source:         "jar_parsed",
+	})
+	require.NoError(t, err)
+
+	data, ok := jarFiltersSection(string(cached))
+	require.True(t, ok)
+	filters := data.(*domain.JARFilterComplexityResponse)
+	assert.Equal(t, "HPD:INC:SendNotification", filters.MostExecuted[0].FilterName)
+	assert.Equal(t, "HPD:INC:SendNotific", filters.MostExecuted[0].FilterNameTruncated.Truncated)
+	assert.Equal(t, "CHG:Approve", filters.MostExecuted[1].FilterName)
+	assert.Equal(t, domain.NameUnresolved, filters.MostExecuted[1].FilterNameTruncated.Confidence)
+
+	_, ok = jarFiltersSection(`{"most_executed":[]}`)
+	assert.False(t, ok, "a computed section is not the JAR's")
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// maxSettingsBytes caps the body of a settings update.
+const maxSettingsBytes = 64 * 1024
+
+// SettingsNotifier announces a new version of the dynamic server settings
+// to the other replicas. storage.RedisClient implements it.
+type SettingsNotifier interface {
+	PublishSettingsChanged(ctx context.Context, version int) error
+}
+
+// SettingsHandler serves GET and PUT /api/v1/admin/settings, the server
+// settings that can be changed without a restart.
+//
+// GET returns every setting with its effective value on this replica and
+// where it comes from. PUT stores the given values as a new version of the
+// dynamic settings, applies it and announces it, so that the other replicas
+// reload it within seconds. The versions keep who changed what.
+type SettingsHandler struct {
+	pg       storage.PostgresStore
+	settings *config.DynamicSettings
+	notifier SettingsNotifier
+}
+
+// NewSettingsHandler creates the handler. notifier may be nil, leaving the
+// other replicas to pick changes up when they next poll.
+func NewSettingsHandler(pg storage.PostgresStore, settings *config.DynamicSettings, notifier SettingsNotifier) *SettingsHandler {
+	return &SettingsHandler{pg: pg, settings: settings, notifier: notifier}
+}
+
+// settingsRequest is the body of PUT /admin/settings. Version is the
+// settings version the admin last read; a null value removes the dynamic
+// value of its setting.
+type settingsRequest struct {
+	Version  *int                       `json:"version"`
+	Settings map[string]json.RawMessage `json:"settings"`
+}
+
+func (h *SettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		noStore(w)
+		api.JSON(w, http.StatusOK, h.settings.Effective())
+	case http.MethodPut:
+		h.put(w, r)
+	default:
+		api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+	}
+}
+
+func (h *SettingsHandler) put(w http.ResponseWriter, r *http.Request) {
+	var req settingsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsBytes)).Decode(&req); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+	if req.Version == nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "version is required")
+		return
+	}
+	if len(req.Settings) == 0 {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "settings is required")
+		return
+	}
+	if err := h.settings.Validate(req.Settings); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	current, err := h.pg.GetServerSettings(ctx)
+	if err != nil {
+		slog.Error("get server settings failed", "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve settings")
+		return
+	}
+	if current.Version != *req.Version {
+		api.Error(w, http.StatusConflict, api.ErrCodeConflict, "settings were changed by someone else; reload and retry")
+		return
+	}
+
+	next := &domain.ServerSettings{
+		Settings:  maps.Clone(current.Settings),
+		CreatedBy: middleware.GetUserID(ctx),
+	}
+	if next.Settings == nil {
+		next.Settings = make(map[string]json.RawMessage)
+	}
+	for k, v := range req.Settings {
+		if string(v) == "null" {
+			delete(next.Settings, k)
+		} else {
+			next.Settings[k] = v
+		}
+	}
+	if err := h.pg.CreateServerSettings(ctx, next, current.Version); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			api.Error(w, http.StatusConflict, api.ErrCodeConflict, "settings were changed by someone else; reload and retry")
+			return
+		}
+		slog.Error("create server settings failed", "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to store settings")
+		return
+	}
+	slog.Info("server settings changed",
+		"version", next.Version,
+		"user_id", next.CreatedBy,
+		"keys", slices.Sorted(maps.Keys(req.Settings)),
+	)
+
+	if _, err := h.settings.Apply(next); err != nil {
+		// Validated above; only a stored value this release rejects fails.
+		slog.Error("apply server settings failed", "version", next.Version, "error", err)
+	}
+	if h.notifier != nil {
+		if err := h.notifier.PublishSettingsChanged(ctx, next.Version); err != nil {
+			slog.Warn("announce server settings change failed", "version", next.Version, "error", err)
+		}
+	}
+	noStore(w)
+	api.JSON(w, http.StatusOK, h.settings.Effective())
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// fakeSettingsNotifier records the announced settings versions.
+type fakeSettingsNotifier struct {
+	versions []int
+	err      error
+}
+
+func (f *fakeSettingsNotifier) PublishSettingsChanged(ctx context.Context, version int) error {
+	f.versions = append(f.versions, version)
+	return f.err
+}
+
+func effectiveSetting(t *testing.T, s *domain.EffectiveSettings, key string) domain.EffectiveSetting {
+	t.Helper()
+	for _, e := range s.Settings {
+		if e.Key == key {
+			return e
+		}
+	}
+	t.Fatalf("setting %s not found", key)
+	return domain.EffectiveSetting{}
+}
+
+func TestSettingsHandler_Get(t *testing.T) {
+	settings := config.NewDynamicSettings(&config.Config{RateLimitSearchPerMin: 90})
+	_, err := settings.Apply(&domain.ServerSettings{Version: 2, CreatedBy: "admin-1", Settings: map[string]json.RawMessage{
+		"log_level": json.RawMessage(`"debug"`),
+	}})
+	require.NoError(t, err)
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/settings").
+		serve(NewSettingsHandler(new(testutil.MockPostgresStore), settings, nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var got domain.EffectiveSettings
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 2, got.Version)
+	assert.Equal(t, "admin-1", got.UpdatedBy)
+
+	level := effectiveSetting(t, &got, "log_level")
+	assert.Equal(t, "debug", level.Value)
+	assert.Equal(t, "info", level.Default)
+	assert.Equal(t, domain.SettingSourceDynamic, level.Source)
+	search := effectiveSetting(t, &got, "rate_limit.search.per_min")
+	assert.EqualValues(t, 90, search.Value)
+	assert.Equal(t, "RATE_LIMIT_SEARCH_PER_MIN", search.Env)
+}
+
+func TestSettingsHandler_Put(t *testing.T) {
+	settings := config.NewDynamicSettings(&config.Config{})
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetServerSettings", mock.Anything).Return(&domain.ServerSettings{Version: 1, Settings: map[string]json.RawMessage{
+		"log_level":            json.RawMessage(`"warn"`),
+		"cache.search_ttl_sec": json.RawMessage(`30`),
+	}}, nil)
+	pg.On("CreateServerSettings", mock.Anything, mock.MatchedBy(func(s *domain.ServerSettings) bool {
+		_, kept := s.Settings["log_level"]
+		_, removed := s.Settings["cache.search_ttl_sec"]
+		return s.CreatedBy == "admin-1" && kept && !removed && string(s.Settings["rate_limit.ai.per_min"]) == "20"
+	}), 1).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.ServerSettings).Version = 2
+	}).Return(nil)
+	notifier := &fakeSettingsNotifier{}
+
+	w := newTestRequest(http.MethodPut, "/api/v1/admin/settings").tenant(fixedTenantID.String()).user("admin-1").
+		jsonBody(t, map[string]any{"version": 1, "settings": map[string]any{
+			"rate_limit.ai.per_min": 20,
+			"cache.search_ttl_sec":  nil,
+		}}).
+		serve(NewSettingsHandler(pg, settings, notifier))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	pg.AssertExpectations(t)
+	assert.Equal(t, []int{2}, notifier.versions)
+	perMin, _ := settings.RateLimit("ai")
+	assert.Equal(t, 20, perMin, "applied on this replica at once")
+	assert.Equal(t, slog.LevelWarn, settings.Level(), "settings kept from the stored version")
+}
+
+func TestSettingsHandler_PutRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       any
+		setup      func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			name:       "unknown key",
+			body:       map[string]any{"version": 0, "settings": map[string]any{"rate_limit.search.perminute": 10}},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    `unknown setting "rate_limit.search.perminute"`,
+		},
+		{
+			name:       "out of bounds",
+			body:       map[string]any{"version": 0, "settings": map[string]any{"jar.default_heap_mb": 64}},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "jar.default_heap_mb must be between 256 and 262144, got 64",
+		},
+		{
+			name:       "wrong type",
+			body:       map[string]any{"version": 0, "settings": map[string]any{"cache.search_ttl_sec": "2m"}},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "cache.search_ttl_sec must be an integer",
+		},
+		{
+			name:       "not a choice",
+			body:       map[string]any{"version": 0, "settings": map[string]any{"log_level": "verbose"}},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    `log_level must be one of debug, info, warn, error, got "verbose"`,
+		},
+		{
+			name:       "no version",
+			body:       map[string]any{"settings": map[string]any{"log_level": "debug"}},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "version is required",
+		},
+		{
+			name:       "no settings",
+			body:       map[string]any{"version": 0},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "settings is required",
+		},
+		{
+			name: "stale version",
+			body: map[string]any{"version": 1, "settings": map[string]any{"log_level": "debug"}},
+			setup: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetServerSettings", mock.Anything).Return(&domain.ServerSettings{Version: 2}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "lost race",
+			body: map[string]any{"version": 2, "settings": map[string]any{"log_level": "debug"}},
+			setup: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetServerSettings", mock.Anything).Return(&domain.ServerSettings{Version: 2}, nil)
+				pg.On("CreateServerSettings", mock.Anything, mock.Anything, 2).Return(storage.ErrVersionConflict)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "store error",
+			body: map[string]any{"version": 0, "settings": map[string]any{"log_level": "debug"}},
+			setup: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetServerSettings", mock.Anything).Return(nil, errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.NewDynamicSettings(&config.Config{})
+			pg := new(testutil.MockPostgresStore)
+			if tt.setup != nil {
+				tt.setup(pg)
+			}
+
+			w := newTestRequest(http.MethodPut, "/api/v1/admin/settings").jsonBody(t, tt.body).
+				serve(NewSettingsHandler(pg, settings, nil))
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantMsg != "" {
+				var body map[string]any
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+				assert.Equal(t, tt.wantMsg, body["message"])
+			}
+			if tt.setup == nil {
+				pg.AssertNotCalled(t, "CreateServerSettings", mock.Anything, mock.Anything, mock.Anything)
+			}
+			assert.Zero(t, settings.Version(), "nothing applied")
+		})
+	}
+}
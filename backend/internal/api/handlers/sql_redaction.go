@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// sqlRedactionBody is the body of GET and PUT .../sql-redaction.
+type sqlRedactionBody struct {
+	Policy domain.SQLRedaction `json:"policy"`
+}
+
+// SQLRedactionHandler serves GET and PUT
+// /api/v1/tenants/{tenant_id}/sql-redaction, the policy applied to the
+// literal values of the SQL statements a tenant's analyses store: off,
+// hash or remove. A change applies to the analyses ingested after it;
+// those already stored are left as they are.
+type SQLRedactionHandler struct {
+	pg storage.PostgresStore
+}
+
+func NewSQLRedactionHandler(pg storage.PostgresStore) *SQLRedactionHandler {
+	return &SQLRedactionHandler{pg: pg}
+}
+
+func (h *SQLRedactionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := pathID(w, r, "tenant_id")
+	if !ok {
+		return
+	}
+	tenant, err := h.pg.GetTenant(r.Context(), tenantID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+		} else {
+			slog.Error("get tenant failed", "tenant_id", tenantID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve tenant")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		policy := tenant.SQLRedaction
+		if policy == "" {
+			policy = domain.SQLRedactionOff
+		}
+		api.JSON(w, http.StatusOK, sqlRedactionBody{Policy: policy})
+	case http.MethodPut:
+		h.put(w, r, tenantID, tenant.SQLRedaction)
+	default:
+		api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+	}
+}
+
+func (h *SQLRedactionHandler) put(w http.ResponseWriter, r *http.Request, tenantID uuid.UUID, from domain.SQLRedaction) {
+	var req sqlRedactionBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+	if !req.Policy.Valid() {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "policy must be off, hash or remove")
+		return
+	}
+
+	if err := h.pg.SetTenantSQLRedaction(r.Context(), tenantID, req.Policy); err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+			return
+		}
+		slog.Error("set sql redaction failed", "tenant_id", tenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to set sql redaction policy")
+		return
+	}
+	slog.Info("tenant sql redaction changed", "tenant_id", tenantID, "from", from, "to", req.Policy)
+
+	api.JSON(w, http.StatusOK, req)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestSQLRedactionHandler(t *testing.T) {
+	tenant := func(policy domain.SQLRedaction) *domain.Tenant {
+		return &domain.Tenant{ID: fixedTenantID, Name: "Acme", SQLRedaction: policy}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		wantPolicy domain.SQLRedaction
+	}{
+		{
+			name:   "get",
+			method: http.MethodGet,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(domain.SQLRedactionHash), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantPolicy: domain.SQLRedactionHash,
+		},
+		{
+			name:   "get of a tenant without a policy",
+			method: http.MethodGet,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(""), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantPolicy: domain.SQLRedactionOff,
+		},
+		{
+			name:   "put",
+			method: http.MethodPut,
+			body:   `{"policy":"remove"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(domain.SQLRedactionOff), nil)
+				pg.On("SetTenantSQLRedaction", mock.Anything, fixedTenantID, domain.SQLRedactionRemove).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantPolicy: domain.SQLRedactionRemove,
+		},
+		{
+			name:   "put of an unknown policy",
+			method: http.MethodPut,
+			body:   `{"policy":"encrypt"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(domain.SQLRedactionOff), nil)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "put without a policy",
+			method: http.MethodPut,
+			body:   `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(domain.SQLRedactionOff), nil)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "put of an invalid body",
+			method: http.MethodPut,
+			body:   `{"policy":`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(domain.SQLRedactionOff), nil)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "put fails to store",
+			method: http.MethodPut,
+			body:   `{"policy":"hash"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(domain.SQLRedactionOff), nil)
+				pg.On("SetTenantSQLRedaction", mock.Anything, fixedTenantID, domain.SQLRedactionHash).Return(errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "unknown tenant",
+			method: http.MethodGet,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(nil, fmt.Errorf("postgres: tenant not found: %s", fixedTenantID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			w := newTestRequest(tc.method, "/api/v1/tenants/"+fixedTenantID.String()+"/sql-redaction").
+				tenant(fixedTenantID.String()).vars("tenant_id", fixedTenantID.String()).
+				rawBody(tc.body).serve(NewSQLRedactionHandler(pg))
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			pg.AssertExpectations(t)
+			if tc.wantPolicy == "" {
+				return
+			}
+			var got sqlRedactionBody
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+			assert.Equal(t, tc.wantPolicy, got.Policy)
+		})
+	}
+}
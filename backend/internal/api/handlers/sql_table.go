@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// sqlTableNameRegex matches the table names the log parser records, such as
+// T4381 or H2197.
+var sqlTableNameRegex = regexp.MustCompile(`^\w{1,128}$`)
+
+// errNoSQLTable is returned for a table no statement of the analysis
+// touched, so that the empty drill-down is not cached.
+var errNoSQLTable = errors.New("no SQL statements on this table")
+
+// SQLTableHandler serves the drill-down of the SQL load of an analysis on
+// one table: its operations, costliest statements, load by hour of the day
+// and the statements suspected of scanning it.
+type SQLTableHandler struct {
+	pg    storage.PostgresStore
+	ch    storage.ClickHouseStore
+	redis storage.RedisCache
+}
+
+func NewSQLTableHandler(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *SQLTableHandler {
+	return &SQLTableHandler{pg: pg, ch: ch, redis: redis}
+}
+
+// ServeHTTP handles GET /api/v1/analyses/{job_id}/sql/tables/{table}. The
+// drill-down is computed on first request and cached like the dashboard
+// sections.
+func (h *SQLTableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	vars := mux.Vars(r)
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+	table := vars["table"]
+	if !sqlTableNameRegex.MatchString(table) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid table name")
+		return
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	if job.Status != domain.JobStatusComplete {
+		sectionNotComplete(w)
+		return
+	}
+
+	etag := sectionETag(job, "sql-table:"+table)
+	if sectionNotModified(w, r, etag) {
+		return
+	}
+
+	cacheKey := h.redis.TenantKey(tenantID, "dashboard", jobID.String()) + ":sql-table:" + table
+	data, cachedAt, err := readLive(r.Context(), h.redis, cacheKey, func(ctx context.Context) (*domain.SQLTableDrilldown, error) {
+		data, err := h.ch.GetSQLTableDrilldown(ctx, tenantID, jobID.String(), table)
+		if err == nil && data.TotalCount == 0 {
+			return nil, errNoSQLTable
+		}
+		return data, err
+	})
+	switch {
+	case errors.Is(err, errNoSQLTable):
+		api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "no SQL statements on this table")
+		return
+	case errors.Is(err, errClickHouseUnavailable):
+		clickHouseUnavailable(w, "sql table drill-down")
+		return
+	case err != nil:
+		slog.Error("failed to get sql table drill-down", "job_id", jobID, "table", table, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "sql table drill-down not available")
+		return
+	}
+	writeLiveSection(w, etag, data, cachedAt)
+}
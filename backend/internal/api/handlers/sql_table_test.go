@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestSQLTableHandler_ServeHTTP(t *testing.T) {
+	completeJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", fixedTenantID, fixedJobID)
+	cacheKey := baseKey + ":sql-table:T4381"
+	drilldown := &domain.SQLTableDrilldown{
+		JobID:           fixedJobID.String(),
+		Table:           "T4381",
+		TotalCount:      12,
+		TotalDurationMS: 3000,
+		Operations:      []domain.SQLOperationStat{{Operation: "SELECT", Count: 12, TotalDurationMS: 3000, AvgDurationMS: 250}},
+		FullScanSuspects: []domain.SQLStatementGroup{{
+			Statement:  "SELECT T4381.C1 FROM T4381",
+			Operation:  "SELECT",
+			Count:      12,
+			Examples:   []string{"SELECT T4381.C1 FROM T4381"},
+			ScanReason: domain.SQLScanNoWhere,
+		}},
+	}
+	cachedJSON, err := json.Marshal(drilldown)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		table      string
+		setupMocks func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache)
+		wantStatus int
+		checkBody  func(t *testing.T, resp domain.SQLTableDrilldown)
+	}{
+		{
+			name: "cache hit",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, cacheKey).Return(string(cachedJSON), nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, resp domain.SQLTableDrilldown) {
+				require.Len(t, resp.FullScanSuspects, 1)
+				assert.Equal(t, domain.SQLScanNoWhere, resp.FullScanSuspects[0].ScanReason)
+			},
+		},
+		{
+			name: "cache miss computes and caches",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, cacheKey).Return("", errors.New("redis: nil"))
+				ch.On("GetSQLTableDrilldown", mock.Anything, fixedTenantID.String(), fixedJobID.String(), "T4381").Return(drilldown, nil)
+				redis.On("Set", mock.Anything, cacheKey, drilldown, sectionCacheTTL).Return(nil)
+				redis.On("Set", mock.Anything, cacheKey+":stale", mock.Anything, staleSectionTTL).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, resp domain.SQLTableDrilldown) {
+				assert.Equal(t, int64(12), resp.TotalCount)
+				assert.Equal(t, "T4381", resp.Table)
+			},
+		},
+		{
+			name: "table without statements",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, cacheKey).Return("", errors.New("redis: nil"))
+				ch.On("GetSQLTableDrilldown", mock.Anything, fixedTenantID.String(), fixedJobID.String(), "T4381").
+					Return(&domain.SQLTableDrilldown{Table: "T4381"}, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "clickhouse failure",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, cacheKey).Return("", errors.New("redis: nil"))
+				ch.On("GetSQLTableDrilldown", mock.Anything, fixedTenantID.String(), fixedJobID.String(), "T4381").
+					Return(nil, errors.New("timeout"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "clickhouse down without cache",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, cacheKey).Return("", errors.New("redis: nil"))
+				ch.On("GetSQLTableDrilldown", mock.Anything, fixedTenantID.String(), fixedJobID.String(), "T4381").
+					Return(nil, errClickHouseDown)
+				redis.On("Get", mock.Anything, cacheKey+":stale").Return("", errors.New("redis: nil"))
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "job still running",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, Status: domain.JobStatusParsing}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "job not found",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "invalid table name",
+			table: "T4381; DROP",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			redis := new(testutil.MockRedisCache)
+			tc.setupMocks(pg, ch, redis)
+
+			table := tc.table
+			if table == "" {
+				table = "T4381"
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analyses/"+fixedJobID.String()+"/sql/tables/T4381", nil)
+			req = req.WithContext(middleware.WithTenantID(req.Context(), fixedTenantID.String()))
+			req = mux.SetURLVars(req, map[string]string{"job_id": fixedJobID.String(), "table": table})
+
+			w := httptest.NewRecorder()
+			NewSQLTableHandler(pg, ch, redis).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				var resp domain.SQLTableDrilldown
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				tc.checkBody(t, resp)
+				assert.NotEmpty(t, w.Header().Get("ETag"))
+			}
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+			redis.AssertExpectations(t)
+		})
+	}
+}
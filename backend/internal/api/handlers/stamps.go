@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+)
+
+// stamps gives the handlers that create records their time and IDs. The
+// zero value stamps by the system clock with random IDs.
+type stamps struct {
+	clock clock.Clock
+	ids   clock.IDGenerator
+}
+
+// SetClock makes the handlers stamp the records they create by c and give
+// them IDs from ids, so that tests can predict both.
+func (s *stamps) SetClock(c clock.Clock, ids clock.IDGenerator) {
+	s.clock = c
+	s.ids = ids
+}
+
+// now returns the time of the clock in UTC.
+func (s *stamps) now() time.Time {
+	if s.clock == nil {
+		return time.Now().UTC()
+	}
+	return s.clock.Now().UTC()
+}
+
+// newID returns the ID of a new record.
+func (s *stamps) newID() uuid.UUID {
+	if s.ids == nil {
+		return clock.RandomIDs.New()
+	}
+	return s.ids.New()
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestCreateAnalysis_StampsByClock(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	noTenantRecord(pg)
+	ns := new(testutil.MockNATSStreamer)
+	pg.On("GetLogFile", mock.Anything, fixedTenantID, fixedFileID).
+		Return(&domain.LogFile{ID: fixedFileID, TenantID: fixedTenantID, SizeBytes: 1 << 20}, nil)
+	var created []*domain.AnalysisJob
+	pg.On("CreateJob", mock.Anything, mock.AnythingOfType("*domain.AnalysisJob")).
+		Run(func(args mock.Arguments) { created = append(created, args.Get(1).(*domain.AnalysisJob)) }).Return(nil)
+	ns.On("PublishJobSubmit", mock.Anything, fixedTenantID.String(), mock.Anything).Return(nil)
+
+	fake := clock.NewFake(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC))
+	h := NewAnalysisHandlers(pg, ns, nil, nil, nil)
+	h.SetClock(fake, clock.NewSequence())
+
+	for i := 0; i < 2; i++ {
+		w := newTestRequest(http.MethodPost, "/api/v1/analysis").
+			tenant(fixedTenantID.String()).rawBody(`{"file_id":"` + fixedFileID.String() + `"}`).serve(h.CreateAnalysis())
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var got domain.AnalysisJob
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, created[i].ID, got.ID)
+		fake.Advance(time.Minute)
+	}
+
+	require.Len(t, created, 2)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000001", created[0].ID.String())
+	assert.Equal(t, "00000000-0000-0000-0000-000000000002", created[1].ID.String())
+	assert.Equal(t, time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC), created[0].CreatedAt)
+	assert.Equal(t, time.Date(2026, 3, 9, 8, 1, 0, 0, time.UTC), created[1].CreatedAt)
+	assert.Equal(t, created[1].CreatedAt, created[1].UpdatedAt)
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
 )
 
@@ -42,7 +47,9 @@ func newUpgrader(allowedOrigins []string) websocket.Upgrader {
 	}
 }
 
-// StreamHandler handles GET /api/v1/ws -- upgrades to WebSocket.
+// StreamHandler handles GET /api/v1/ws -- upgrades to WebSocket. The
+// protocol query parameter lists the protocol versions the client speaks,
+// e.g. ?protocol=1,2; the connection uses the highest one the hub speaks.
 type StreamHandler struct {
 	hub      *streaming.Hub
 	upgrader websocket.Upgrader
@@ -62,6 +69,12 @@ func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	protocol, err := h.hub.NegotiateProtocol(r.URL.Query().Get("protocol"))
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("websocket upgrade failed", "error", err)
@@ -69,8 +82,74 @@ func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// NewClient auto-registers with the hub.
-	client := streaming.NewClient(h.hub, conn, tenantID)
+	client := streaming.NewClientWithProtocol(h.hub, conn, tenantID, protocol)
 
 	go client.WritePump()
 	go client.ReadPump()
 }
+
+// SSEHandler handles GET /api/v1/stream/sse -- the server-sent events
+// fallback of /ws for clients behind proxies that block WebSocket
+// upgrades. topics lists the subscriptions, e.g.
+// ?topics=job_progress:<job_id>,live_tail:api,investigations, and protocol
+// is negotiated as for /ws. A reconnecting browser's Last-Event-ID header,
+// or the last_event_id parameter, resumes the job events it missed.
+type SSEHandler struct {
+	hub *streaming.Hub
+}
+
+func NewSSEHandler(hub *streaming.Hub) *SSEHandler {
+	return &SSEHandler{hub: hub}
+}
+
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	q := r.URL.Query()
+	protocol, err := h.hub.NegotiateProtocol(q.Get("protocol"))
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	client := streaming.NewSSEClient(h.hub, tenantID, protocol)
+	if err := client.Subscribe(q.Get("topics")); err != nil {
+		client.Close()
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = q.Get("last_event_id")
+	}
+	client.Resume(r.Context(), lastEventID)
+	client.Serve(r.Context(), w)
+}
+
+// JobStates gives the WebSocket hub the current state of a tenant's jobs,
+// from which resumed event streams synthesize the job events they missed.
+type JobStates struct {
+	pg storage.PostgresStore
+}
+
+// NewJobStates creates the job state source of the WebSocket hub.
+func NewJobStates(pg storage.PostgresStore) *JobStates {
+	return &JobStates{pg: pg}
+}
+
+// JobState returns the job of the tenant.
+func (s *JobStates) JobState(ctx context.Context, tenantID, jobID string) (*domain.AnalysisJob, error) {
+	tid, err := middleware.ParseID(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant_id: %w", err)
+	}
+	jid, err := middleware.ParseID(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job_id: %w", err)
+	}
+	return s.pg.GetJob(ctx, tid, jid)
+}
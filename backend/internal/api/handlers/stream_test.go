@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bufio"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
 )
 
@@ -117,6 +119,44 @@ func TestStreamHandler_SuccessfulWebSocketUpgrade(t *testing.T) {
 	assert.Equal(t, "pong", serverMsg.Type)
 }
 
+func TestStreamHandler_NegotiatesProtocolVersion(t *testing.T) {
+	hub := streaming.NewHub()
+	go hub.Run()
+
+	handler := NewStreamHandler(hub, []string{"*"})
+	wrappedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := middleware.WithTenantID(r.Context(), "test-tenant")
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+	srv := httptest.NewServer(wrappedHandler)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/ws"
+
+	pong := func(t *testing.T, query string) map[string]interface{} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+query, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.WriteJSON(streaming.ClientMessage{Type: "ping"}))
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&msg))
+		return msg
+	}
+
+	t.Run("no version is protocol 1", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{"type": "pong"}, pong(t, ""))
+	})
+	t.Run("highest common version", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{"type": "pong", "version": float64(2)}, pong(t, "?protocol=1,2,3"))
+	})
+	t.Run("no common version", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?protocol=3", nil)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
 func TestStreamHandler_WebSocketMessageHandling(t *testing.T) {
 	hub := streaming.NewHub()
 	go hub.Run()
@@ -255,3 +295,58 @@ func TestNewStreamHandler_Constructor(t *testing.T) {
 	assert.NotNil(t, handler)
 	assert.NotNil(t, handler.hub)
 }
+
+// ---------------------------------------------------------------------------
+// SSEHandler.ServeHTTP tests
+// ---------------------------------------------------------------------------
+
+func TestSSEHandler_MissingTenantContext(t *testing.T) {
+	hub := streaming.NewHub()
+	go hub.Run()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream/sse?topics=investigations", nil)
+	w := httptest.NewRecorder()
+	NewSSEHandler(hub).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSSEHandler_InvalidTopics(t *testing.T) {
+	hub := streaming.NewHub()
+	go hub.Run()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream/sse?topics=job_progress:not-a-job", nil)
+	req = req.WithContext(middleware.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	NewSSEHandler(hub).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "not-a-job")
+}
+
+func TestSSEHandler_StreamsInvestigations(t *testing.T) {
+	hub := streaming.NewHub()
+	go hub.Run()
+	handler := NewSSEHandler(hub)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(middleware.WithTenantID(r.Context(), "test-tenant")))
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/stream/sse?topics=investigations")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	hub.BroadcastInvestigation("test-tenant", domain.InvestigationEvent{ToStatus: domain.InvestigationInvestigating})
+
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: investigation_updated\n", line)
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `"to_status":"investigating"`)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// defaultSupportAccessHours is how long support access lasts when the
+	// grant does not say.
+	defaultSupportAccessHours = 72
+	// maxSupportAccessHours caps the lifetime of a grant to 30 days.
+	maxSupportAccessHours = 30 * 24
+	// maxSupportAccessReasonLength caps the reason of a grant.
+	maxSupportAccessReasonLength = 1000
+
+	defaultSupportActivityLimit = 100
+	maxSupportActivityLimit     = 1000
+)
+
+// supportGrantRequest is the optional body of
+// POST /api/v1/tenants/{tenant_id}/support-access.
+type supportGrantRequest struct {
+	DurationHours int    `json:"duration_hours"`
+	ReadOnly      bool   `json:"read_only"`
+	Reason        string `json:"reason"`
+}
+
+// supportGrantResponse is a grant as listed to the tenant.
+type supportGrantResponse struct {
+	domain.SupportGrant
+	Active bool `json:"active"`
+}
+
+// SupportAccessHandlers let tenant administrators grant platform support
+// time-boxed access to their tenant, revoke it, and review every request
+// support made under it.
+type SupportAccessHandlers struct {
+	pg  storage.PostgresStore
+	now func() time.Time
+}
+
+// NewSupportAccessHandlers creates the support access handlers.
+func NewSupportAccessHandlers(pg storage.PostgresStore) *SupportAccessHandlers {
+	return &SupportAccessHandlers{pg: pg, now: time.Now}
+}
+
+// tenantID returns the {tenant_id} of the request after checking that it is
+// the tenant the caller acts in. It writes the error response otherwise.
+func (h *SupportAccessHandlers) tenantID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tid, ok := pathID(w, r, "tenant_id")
+	if !ok {
+		return uuid.Nil, false
+	}
+	if tid.String() != middleware.GetTenantID(r.Context()) {
+		api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "support access of another tenant is not available")
+		return uuid.Nil, false
+	}
+	return tid, true
+}
+
+// requireTenantAdmin writes 403 Forbidden unless the caller administers the
+// tenant. Support engineers acting under a grant never do, so that they
+// cannot extend or revoke their own access.
+func requireTenantAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !middleware.IsTenantAdmin(r.Context()) {
+		api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "tenant administrator access required")
+		return false
+	}
+	return true
+}
+
+// CreateGrant handles POST /api/v1/tenants/{tenant_id}/support-access. The
+// grant lasts duration_hours (72 by default, at most 30 days).
+func (h *SupportAccessHandlers) CreateGrant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantID(w, r)
+		if !ok || !requireTenantAdmin(w, r) {
+			return
+		}
+
+		var req supportGrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		if req.DurationHours == 0 {
+			req.DurationHours = defaultSupportAccessHours
+		}
+		if req.DurationHours < 0 || req.DurationHours > maxSupportAccessHours {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "duration_hours must be between 1 and 720")
+			return
+		}
+		if len(req.Reason) > maxSupportAccessReasonLength {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "reason must be at most 1000 bytes")
+			return
+		}
+
+		now := h.now().UTC()
+		grant := &domain.SupportGrant{
+			ID:        uuid.New(),
+			TenantID:  tid,
+			GrantedBy: middleware.GetUserID(r.Context()),
+			Reason:    req.Reason,
+			ReadOnly:  req.ReadOnly,
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Duration(req.DurationHours) * time.Hour),
+		}
+		if err := h.pg.CreateSupportGrant(r.Context(), grant); err != nil {
+			slog.Error("failed to create support grant", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create support access grant")
+			return
+		}
+
+		slog.Info("support access granted", "tenant_id", tid, "grant_id", grant.ID,
+			"granted_by", grant.GrantedBy, "expires_at", grant.ExpiresAt, "read_only", grant.ReadOnly)
+		api.JSON(w, http.StatusCreated, supportGrantResponse{SupportGrant: *grant, Active: true})
+	})
+}
+
+// ListGrants handles GET /api/v1/tenants/{tenant_id}/support-access.
+func (h *SupportAccessHandlers) ListGrants() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantID(w, r)
+		if !ok {
+			return
+		}
+
+		grants, err := h.pg.ListSupportGrants(r.Context(), tid)
+		if err != nil {
+			slog.Error("failed to list support grants", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list support access grants")
+			return
+		}
+		now := h.now()
+		resp := make([]supportGrantResponse, 0, len(grants))
+		for _, g := range grants {
+			resp = append(resp, supportGrantResponse{SupportGrant: g, Active: g.Active(now)})
+		}
+		api.JSON(w, http.StatusOK, map[string]any{"grants": resp})
+	})
+}
+
+// RevokeGrant handles DELETE /api/v1/tenants/{tenant_id}/support-access/{grant_id}.
+// Support loses access with the next request.
+func (h *SupportAccessHandlers) RevokeGrant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantID(w, r)
+		if !ok || !requireTenantAdmin(w, r) {
+			return
+		}
+		grantID, ok := pathID(w, r, "grant_id")
+		if !ok {
+			return
+		}
+
+		userID := middleware.GetUserID(r.Context())
+		if err := h.pg.RevokeSupportGrant(r.Context(), tid, grantID, userID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "active support access grant not found")
+			} else {
+				slog.Error("failed to revoke support grant", "tenant_id", tid, "grant_id", grantID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to revoke support access grant")
+			}
+			return
+		}
+
+		slog.Info("support access revoked", "tenant_id", tid, "grant_id", grantID, "revoked_by", userID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ListActivity handles GET /api/v1/tenants/{tenant_id}/support-access/activity?limit=,
+// the latest requests support made in the tenant.
+func (h *SupportAccessHandlers) ListActivity() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantID(w, r)
+		if !ok {
+			return
+		}
+		limit := defaultSupportActivityLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxSupportActivityLimit {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "limit must be between 1 and 1000")
+				return
+			}
+			limit = n
+		}
+
+		events, err := h.pg.ListSupportAccessEvents(r.Context(), tid, limit)
+		if err != nil {
+			slog.Error("failed to list support access activity", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list support access activity")
+			return
+		}
+		api.JSON(w, http.StatusOK, map[string]any{"events": events})
+	})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var supportNow = time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+
+// supportRequest builds a request of the tenant admin "admin-1" of tenantID
+// with the given route vars. role "" makes the user a plain member.
+func supportRequest(method, body string, tenantID uuid.UUID, role string, vars map[string]string) *http.Request {
+	req := httptest.NewRequest(method, "/api/v1/tenants/"+tenantID.String()+"/support-access", strings.NewReader(body))
+	ctx := middleware.WithUserID(req.Context(), "admin-1")
+	ctx = middleware.WithTenantID(ctx, tenantID.String())
+	ctx = middleware.WithOrgID(ctx, tenantID.String())
+	ctx = middleware.WithOrgRole(ctx, role)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["tenant_id"] = tenantID.String()
+	return mux.SetURLVars(req.WithContext(ctx), vars)
+}
+
+func newSupportAccessHandlers(pg *testutil.MockPostgresStore) *SupportAccessHandlers {
+	h := NewSupportAccessHandlers(pg)
+	h.now = func() time.Time { return supportNow }
+	return h
+}
+
+func TestSupportAccessHandlers_CreateGrant(t *testing.T) {
+	tenantID := uuid.New()
+
+	t.Run("created", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("CreateSupportGrant", mock.Anything, mock.MatchedBy(func(g *domain.SupportGrant) bool {
+			return g.TenantID == tenantID && g.GrantedBy == "admin-1" && g.ReadOnly &&
+				g.Reason == "ticket 42" && g.ExpiresAt.Equal(supportNow.Add(24*time.Hour))
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		req := supportRequest(http.MethodPost, `{"duration_hours":24,"read_only":true,"reason":"ticket 42"}`, tenantID, "org:admin", nil)
+		newSupportAccessHandlers(pg).CreateGrant().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		var got supportGrantResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.True(t, got.Active)
+		assert.Equal(t, tenantID, got.TenantID)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("defaults without body", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("CreateSupportGrant", mock.Anything, mock.MatchedBy(func(g *domain.SupportGrant) bool {
+			return !g.ReadOnly && g.ExpiresAt.Equal(supportNow.Add(72*time.Hour))
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		newSupportAccessHandlers(pg).CreateGrant().ServeHTTP(w, supportRequest(http.MethodPost, "", tenantID, "org:admin", nil))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		pg.AssertExpectations(t)
+	})
+
+	tests := []struct {
+		name        string
+		body        string
+		role        string
+		pathTenant  uuid.UUID
+		impersonate bool
+		wantStatus  int
+	}{
+		{name: "not an admin", body: `{}`, role: "org:member", wantStatus: http.StatusForbidden},
+		{name: "impersonated", body: `{}`, role: "org:admin", impersonate: true, wantStatus: http.StatusForbidden},
+		{name: "another tenant", body: `{}`, role: "org:admin", pathTenant: uuid.New(), wantStatus: http.StatusForbidden},
+		{name: "duration too long", body: `{"duration_hours":721}`, role: "org:admin", wantStatus: http.StatusBadRequest},
+		{name: "negative duration", body: `{"duration_hours":-1}`, role: "org:admin", wantStatus: http.StatusBadRequest},
+		{name: "reason too long", body: fmt.Sprintf(`{"reason":%q}`, strings.Repeat("x", 1001)), role: "org:admin", wantStatus: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{`, role: "org:admin", wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			req := supportRequest(http.MethodPost, tc.body, tenantID, tc.role, nil)
+			if tc.pathTenant != uuid.Nil {
+				req = mux.SetURLVars(req, map[string]string{"tenant_id": tc.pathTenant.String()})
+			}
+			if tc.impersonate {
+				req = req.WithContext(middleware.WithImpersonation(req.Context(), &middleware.Impersonation{GrantID: uuid.New()}))
+			}
+
+			w := httptest.NewRecorder()
+			newSupportAccessHandlers(pg).CreateGrant().ServeHTTP(w, req)
+			assert.Equal(t, tc.wantStatus, w.Code)
+			pg.AssertNotCalled(t, "CreateSupportGrant", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// TestSupportAccessHandlers_CreateGrantDevBypass checks that a dev user
+// grants support access only when the request asks for the admin role.
+func TestSupportAccessHandlers_CreateGrantDevBypass(t *testing.T) {
+	tenantID := uuid.New()
+	for _, tc := range []struct {
+		role       string
+		wantStatus int
+	}{
+		{role: "", wantStatus: http.StatusForbidden},
+		{role: "org:admin", wantStatus: http.StatusCreated},
+	} {
+		t.Run("role "+tc.role, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			if tc.wantStatus == http.StatusCreated {
+				pg.On("CreateSupportGrant", mock.Anything, mock.AnythingOfType("*domain.SupportGrant")).Return(nil)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tenants/"+tenantID.String()+"/support-access", nil)
+			req.Header.Set("X-Dev-User-ID", "admin-1")
+			req.Header.Set("X-Dev-Tenant-ID", tenantID.String())
+			if tc.role != "" {
+				req.Header.Set("X-Dev-Org-Role", tc.role)
+			}
+			req = mux.SetURLVars(req, map[string]string{"tenant_id": tenantID.String()})
+
+			w := httptest.NewRecorder()
+			middleware.NewAuthMiddleware("test-secret", true).Authenticate(newSupportAccessHandlers(pg).CreateGrant()).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSupportAccessHandlers_ListGrants(t *testing.T) {
+	tenantID := uuid.New()
+	revokedAt := supportNow.Add(-time.Hour)
+	grants := []domain.SupportGrant{
+		{ID: uuid.New(), TenantID: tenantID, ExpiresAt: supportNow.Add(time.Hour)},
+		{ID: uuid.New(), TenantID: tenantID, ExpiresAt: supportNow.Add(time.Hour), RevokedAt: &revokedAt},
+		{ID: uuid.New(), TenantID: tenantID, ExpiresAt: supportNow.Add(-time.Hour)},
+	}
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListSupportGrants", mock.Anything, tenantID).Return(grants, nil)
+
+	w := httptest.NewRecorder()
+	newSupportAccessHandlers(pg).ListGrants().ServeHTTP(w, supportRequest(http.MethodGet, "", tenantID, "", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got struct {
+		Grants []supportGrantResponse `json:"grants"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Len(t, got.Grants, 3)
+	assert.True(t, got.Grants[0].Active)
+	assert.False(t, got.Grants[1].Active, "revoked")
+	assert.False(t, got.Grants[2].Active, "expired")
+}
+
+func TestSupportAccessHandlers_RevokeGrant(t *testing.T) {
+	tenantID := uuid.New()
+	grantID := uuid.New()
+
+	tests := []struct {
+		name       string
+		storeErr   error
+		wantStatus int
+	}{
+		{name: "revoked", wantStatus: http.StatusNoContent},
+		{name: "not found", storeErr: fmt.Errorf("postgres: support grant not found: %s", grantID), wantStatus: http.StatusNotFound},
+		{name: "store error", storeErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			pg.On("RevokeSupportGrant", mock.Anything, tenantID, grantID, "admin-1").Return(tc.storeErr)
+
+			w := httptest.NewRecorder()
+			req := supportRequest(http.MethodDelete, "", tenantID, "org:admin", map[string]string{"grant_id": grantID.String()})
+			newSupportAccessHandlers(pg).RevokeGrant().ServeHTTP(w, req)
+			assert.Equal(t, tc.wantStatus, w.Code)
+			pg.AssertExpectations(t)
+		})
+	}
+
+	t.Run("not an admin", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		w := httptest.NewRecorder()
+		req := supportRequest(http.MethodDelete, "", tenantID, "org:member", map[string]string{"grant_id": grantID.String()})
+		newSupportAccessHandlers(pg).RevokeGrant().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestSupportAccessHandlers_ListActivity(t *testing.T) {
+	tenantID := uuid.New()
+
+	t.Run("events", func(t *testing.T) {
+		events := []domain.SupportAccessEvent{{ID: uuid.New(), TenantID: tenantID, ActorUserID: "support-1", Method: http.MethodGet, Path: "/api/v1/analysis", Status: 200}}
+		pg := new(testutil.MockPostgresStore)
+		pg.On("ListSupportAccessEvents", mock.Anything, tenantID, 10).Return(events, nil)
+
+		w := httptest.NewRecorder()
+		req := supportRequest(http.MethodGet, "", tenantID, "", nil)
+		req.URL.RawQuery = "limit=10"
+		newSupportAccessHandlers(pg).ListActivity().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var got struct {
+			Events []domain.SupportAccessEvent `json:"events"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		require.Len(t, got.Events, 1)
+		assert.Equal(t, "support-1", got.Events[0].ActorUserID)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := supportRequest(http.MethodGet, "", tenantID, "", nil)
+		req.URL.RawQuery = "limit=5000"
+		newSupportAccessHandlers(new(testutil.MockPostgresStore)).ListActivity().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
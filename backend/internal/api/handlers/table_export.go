@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tabular"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// Table formats a section can be exported in besides JSON.
+const (
+	tableFormatCSV  = "csv"
+	tableFormatXLSX = "xlsx"
+)
+
+// tableFormat returns the format a section is requested in: ?format=csv or
+// xlsx, else an Accept header naming one of their content types. It is
+// empty for JSON and false for an unknown ?format.
+func tableFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case tableFormatCSV, tableFormatXLSX:
+		return format, true
+	case "", "json":
+	default:
+		return "", false
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/csv"):
+		return tableFormatCSV, true
+	case strings.Contains(accept, tabular.ContentTypeXLSX):
+		return tableFormatXLSX, true
+	}
+	return "", true
+}
+
+// sectionVariant names the representation of a section r asks for, to
+// tell the ETags of its JSON and table exports apart.
+func sectionVariant(r *http.Request, section, format string) string {
+	if format == "" {
+		return section
+	}
+	return section + ":" + format + ":" + r.URL.Query().Get("table")
+}
+
+// badTableFormat rejects an unknown ?format.
+func badTableFormat(w http.ResponseWriter) {
+	api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "format must be one of json, csv, xlsx")
+}
+
+// writeSectionTable writes one table of a section as an attachment in
+// format: the table named by ?table, by default the first with rows. The
+// section data is the same the JSON response carries; complete is as for
+// writeSection.
+func writeSectionTable(w http.ResponseWriter, r *http.Request, pg storage.PostgresStore, job *domain.AnalysisJob,
+	etag, section string, data any, format string, complete bool) {
+	name := r.URL.Query().Get("table")
+	table, ok := tabular.Lookup(data, name)
+	if !ok {
+		names := tabular.Names(data)
+		if len(names) == 0 {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, section+" has no table to export")
+			return
+		}
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+			fmt.Sprintf("table must be one of %s", strings.Join(names, ", ")))
+		return
+	}
+
+	contentType, write := tabular.ContentTypeCSV, tabular.WriteCSV
+	if format == tableFormatXLSX {
+		prov := sectionProvenance(job, section, table.Name, middleware.GetUserID(r.Context()), time.Now())
+		contentType = tabular.ContentTypeXLSX
+		write = func(w io.Writer, t tabular.Table) error {
+			return tabular.WriteXLSX(w, t, provenanceTable(prov))
+		}
+	}
+	if complete {
+		setSectionCacheHeaders(w, etag)
+	} else {
+		noStore(w)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		tableFilename(r.Context(), pg, job, section, table.Name)+"."+format))
+	w.WriteHeader(http.StatusOK)
+	if err := write(w, table); err != nil {
+		slog.Warn("section table export interrupted", "job_id", job.ID, "section", section, "table", table.Name, "error", err)
+	}
+}
+
+// sectionProvenance returns the provenance of an export of one section
+// table of job. Its query names the section and table.
+func sectionProvenance(job *domain.AnalysisJob, section, table, userID string, now time.Time) domain.ArtifactProvenance {
+	query, _ := json.Marshal(map[string]string{"section": section, "table": table})
+	analysis := domain.ProvenanceAnalysis{JobID: job.ID}
+	if job.CompletedAt != nil {
+		completed := job.CompletedAt.UTC()
+		analysis.CompletedAt = &completed
+	}
+	return domain.ArtifactProvenance{
+		Format:        tableFormatXLSX,
+		Query:         query,
+		Analyses:      []domain.ProvenanceAnalysis{analysis},
+		ParserVersion: worker.ParserVersion,
+		GeneratedBy:   userID,
+		GeneratedAt:   now.UTC().Truncate(time.Second),
+	}
+}
+
+// provenanceTable lays prov out as the "Provenance" sheet of a workbook.
+func provenanceTable(prov domain.ArtifactProvenance) tabular.Table {
+	t := tabular.Table{Name: "Provenance", Headers: []string{"field", "value"}}
+	for _, f := range prov.Fields() {
+		t.Rows = append(t.Rows, []any{f.Name, f.Value})
+	}
+	return t
+}
+
+// tableFilename names the export of a section table after the analysed log
+// file, or the job when the file is gone: "arserver-aggregates-api.groups".
+func tableFilename(ctx context.Context, pg storage.PostgresStore, job *domain.AnalysisJob, section, table string) string {
+	base := "analysis-" + job.ID.String()[:8]
+	if file, err := pg.GetLogFile(ctx, job.TenantID, job.FileID); err == nil && file.Filename != "" {
+		base = strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	}
+	parts := []string{base, section}
+	if table != "" && table != section {
+		parts = append(parts, table)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.Join(parts, "-"))
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tabular"
+)
+
+func TestSectionTableExport(t *testing.T) {
+	baseKey := "tenant:" + fixedTenantID.String() + ":dashboard:" + fixedJobID.String()
+	job := completedJob(fixedTenantID, fixedJobID)
+
+	// setup serves sc from the cache; the analysed file is named
+	// arserver.log unless fileErr is set.
+	setup := func(t *testing.T, sc sectionCase, fileErr error) *handlerMocks {
+		m := newHandlerMocks()
+		m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+		m.pg.On("GetLogFile", mock.Anything, fixedTenantID, job.FileID).
+			Return(&domain.LogFile{Filename: "arserver.log"}, fileErr).Maybe()
+		m.redis.On("TenantKey", fixedTenantID.String(), "dashboard", fixedJobID.String()).Return(baseKey)
+		cached, err := json.Marshal(sc.cached)
+		require.NoError(t, err)
+		m.redis.On("Get", mock.Anything, baseKey+":"+sc.cacheKey).Return(string(cached), nil).Maybe()
+		m.ch.On("GetQueueLoad", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+			Return(nil, errors.New("clickhouse unavailable")).Maybe()
+		return m
+	}
+	cases := map[string]sectionCase{}
+	for _, sc := range sectionCases() {
+		cases[sc.name] = sc
+	}
+	request := func(sc sectionCase, query string) *testRequest {
+		return newTestRequest(http.MethodGet, "/api/v1/analysis/"+fixedJobID.String()+"/dashboard/"+sc.path+query).
+			tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String())
+	}
+
+	t.Run("aggregates as CSV", func(t *testing.T) {
+		sc := cases["aggregates"]
+		m := setup(t, sc, nil)
+
+		w := request(sc, "?format=csv").serve(sc.handler(m.pg, m.ch, m.redis))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tabular.ContentTypeCSV, w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="arserver-aggregates-api.groups.csv"`, w.Header().Get("Content-Disposition"))
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"name", "count", "total_ms", "avg_ms", "min_ms", "max_ms", "error_count", "error_rate", "unique_traces", "p95_ms", "timed_count", "avg_timed_ms", "p95_timed_ms"},
+			{"HPD:Help Desk", "3", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0"},
+		}, records)
+		m.assertExpectations(t)
+	})
+
+	t.Run("exceptions as XLSX by Accept", func(t *testing.T) {
+		sc := cases["exceptions"]
+		m := setup(t, sc, errors.New("file gone"))
+
+		w := request(sc, "").header("Accept", tabular.ContentTypeXLSX).serve(sc.handler(m.pg, m.ch, m.redis))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tabular.ContentTypeXLSX, w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="analysis-`+fixedJobID.String()[:8]+`-exceptions.xlsx"`, w.Header().Get("Content-Disposition"))
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		require.NoError(t, err)
+		parts := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			b, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			parts[f.Name] = string(b)
+		}
+		sheet := parts["xl/worksheets/sheet1.xml"]
+		assert.Contains(t, sheet, `<t xml:space="preserve">error_code</t>`)
+		assert.Contains(t, sheet, `<t xml:space="preserve">ARERR 302</t>`)
+		assert.Contains(t, sheet, `<c r="C2"><v>2</v></c>`, "counts are numbers")
+
+		assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Provenance" sheetId="2" r:id="rId2"/>`)
+		provenance := parts["xl/worksheets/sheet2.xml"]
+		assert.Contains(t, provenance, `<t xml:space="preserve">`+fixedJobID.String())
+		assert.Contains(t, provenance, `<t xml:space="preserve">parser_version</t>`)
+		assert.Contains(t, provenance, `<t xml:space="preserve">{&#34;section&#34;:&#34;exceptions&#34;,&#34;table&#34;:&#34;`)
+		m.assertExpectations(t)
+	})
+
+	t.Run("every section exports", func(t *testing.T) {
+		for _, sc := range sectionCases() {
+			m := setup(t, sc, nil)
+			w := request(sc, "?format=csv").serve(sc.handler(m.pg, m.ch, m.redis))
+			require.Equal(t, http.StatusOK, w.Code, sc.name)
+			records, err := csv.NewReader(w.Body).ReadAll()
+			require.NoError(t, err, sc.name)
+			assert.Len(t, records, 2, sc.name)
+		}
+	})
+
+	t.Run("JSON and table ETags differ", func(t *testing.T) {
+		sc := cases["gaps"]
+		m := setup(t, sc, nil)
+		h := sc.handler(m.pg, m.ch, m.redis)
+		asJSON := request(sc, "").serve(h)
+		asCSV := request(sc, "?format=csv").serve(h)
+		require.Equal(t, http.StatusOK, asJSON.Code)
+		assert.NotEqual(t, asJSON.Header().Get("ETag"), asCSV.Header().Get("ETag"))
+		assert.Equal(t, "Accept", asJSON.Header().Get("Vary"))
+	})
+
+	t.Run("named table", func(t *testing.T) {
+		sc := cases["gaps"]
+		m := setup(t, sc, nil)
+		w := request(sc, "?format=csv&table=queue_health").serve(sc.handler(m.pg, m.ch, m.redis))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "arserver-gaps-queue_health.csv")
+	})
+
+	t.Run("unknown table", func(t *testing.T) {
+		sc := cases["gaps"]
+		m := setup(t, sc, nil)
+		w := request(sc, "?format=csv&table=nope").serve(sc.handler(m.pg, m.ch, m.redis))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, decodeError(t, w).Message, "gaps, queue_health, restarts")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		sc := cases["threads"]
+		m := setup(t, sc, nil)
+		w := request(sc, "?format=pdf").serve(sc.handler(m.pg, m.ch, m.redis))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, decodeError(t, w).Message, "format must be one of")
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tenantconfig"
+)
+
+// maxTenantConfigBytes caps the body of a configuration import.
+const maxTenantConfigBytes = 1 << 20
+
+// tenantGetter looks up a tenant. storage.PostgresStore implements it.
+type tenantGetter interface {
+	GetTenant(ctx context.Context, id uuid.UUID) (*domain.Tenant, error)
+}
+
+// TenantConfigHandlers export a tenant's declarative configuration as YAML
+// and apply such documents, so that environments can be managed from
+// version control.
+type TenantConfigHandlers struct {
+	tenants tenantGetter
+	configs *tenantconfig.Manager
+}
+
+// NewTenantConfigHandlers creates the tenant configuration handlers.
+func NewTenantConfigHandlers(tenants tenantGetter, configs *tenantconfig.Manager) *TenantConfigHandlers {
+	return &TenantConfigHandlers{tenants: tenants, configs: configs}
+}
+
+// tenantConfigImportResponse is the response of a configuration import.
+// Changes are those planned; Applied and Failed are only set when the
+// import was not a dry run.
+type tenantConfigImportResponse struct {
+	DryRun  bool                         `json:"dry_run"`
+	Prune   bool                         `json:"prune"`
+	Changes []tenantconfig.Change        `json:"changes"`
+	Applied []tenantconfig.Change        `json:"applied,omitempty"`
+	Failed  *tenantconfig.SectionFailure `json:"failed,omitempty"`
+}
+
+// tenant parses the tenant of the path and checks that it exists, writing
+// the error response otherwise.
+func (h *TenantConfigHandlers) tenant(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tid, ok := pathID(w, r, "tenant_id")
+	if !ok {
+		return uuid.Nil, false
+	}
+	if _, err := h.tenants.GetTenant(r.Context(), tid); err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+		} else {
+			slog.Error("failed to load tenant for config", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to load tenant")
+		}
+		return uuid.Nil, false
+	}
+	return tid, true
+}
+
+// Export handles GET /api/v1/tenants/{tenant_id}/config-export.
+func (h *TenantConfigHandlers) Export() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenant(w, r)
+		if !ok {
+			return
+		}
+
+		doc, err := h.configs.Export(r.Context(), tid)
+		if err != nil {
+			slog.Error("failed to export tenant config", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to export tenant configuration")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Content-Disposition", `attachment; filename="tenant-config-`+tid.String()+`.yaml"`)
+		noStore(w)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(doc)
+	})
+}
+
+// Import handles PUT /api/v1/tenants/{tenant_id}/config-import. The body is
+// a document as exported. With ?dry_run=true the planned changes are
+// returned and nothing is stored; resources missing from the document are
+// only deleted with ?prune=true. Each section is applied all or nothing.
+func (h *TenantConfigHandlers) Import() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dryRun, err := queryBool(r, "dry_run")
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "dry_run must be true or false")
+			return
+		}
+		prune, err := queryBool(r, "prune")
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "prune must be true or false")
+			return
+		}
+		tid, ok := h.tenant(w, r)
+		if !ok {
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTenantConfigBytes))
+		if err != nil {
+			api.Error(w, http.StatusRequestEntityTooLarge, api.ErrCodeInvalidRequest, "configuration document must be at most 1 MiB")
+			return
+		}
+		doc, err := h.configs.Parse(body)
+		if err != nil {
+			var verrs tenantconfig.ValidationErrors
+			if errors.As(err, &verrs) {
+				api.ErrorWithDetails(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest, "invalid configuration document", verrs)
+				return
+			}
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		changes, err := h.configs.Plan(r.Context(), tid, doc, prune)
+		if err != nil {
+			slog.Error("failed to plan tenant config import", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to read the current configuration")
+			return
+		}
+		resp := tenantConfigImportResponse{DryRun: dryRun, Prune: prune, Changes: changes}
+		if resp.Changes == nil {
+			resp.Changes = []tenantconfig.Change{}
+		}
+		if dryRun {
+			api.JSON(w, http.StatusOK, resp)
+			return
+		}
+
+		userID := middleware.GetUserID(r.Context())
+		result := h.configs.Apply(tenantconfig.WithActor(r.Context(), userID), tid, changes)
+		for _, c := range result.Applied {
+			slog.Info("tenant config changed", "tenant_id", tid, "user_id", userID,
+				"section", c.Section, "key", c.Key, "action", c.Action)
+		}
+		resp.Applied, resp.Failed = result.Applied, result.Failed
+		if result.Failed != nil {
+			for _, c := range result.Failed.RolledBack {
+				slog.Info("tenant config change rolled back", "tenant_id", tid, "user_id", userID,
+					"section", c.Section, "key", c.Key, "action", c.Action)
+			}
+			slog.Error("tenant config import failed", "tenant_id", tid, "section", result.Failed.Section,
+				"key", result.Failed.Key, "error", result.Failed.Error, "rollback_errors", result.Failed.RollbackErrors)
+			api.ErrorWithDetails(w, http.StatusInternalServerError, api.ErrCodeInternalError,
+				"failed to apply "+result.Failed.Section+"; its changes were rolled back", resp)
+			return
+		}
+		api.JSON(w, http.StatusOK, resp)
+	})
+}
+
+// queryBool parses an optional boolean query parameter.
+func queryBool(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
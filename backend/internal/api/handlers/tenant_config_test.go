@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tenantconfig"
+)
+
+// expectTenantConfig sets up a tenant with one threshold rule and nothing
+// else configured.
+func expectTenantConfig(m *handlerMocks) {
+	m.pg.On("GetTenant", mock.Anything, fixedTenantID).Return(&domain.Tenant{ID: fixedTenantID}, nil)
+	m.pg.On("ListThresholdRules", mock.Anything, fixedTenantID).Return([]domain.ThresholdRule{{
+		ID: uuid.New(), TenantID: fixedTenantID, Name: "errors", Scope: domain.ThresholdScopeGlobal,
+		Metric: domain.ThresholdMetricErrorRate, Comparator: domain.ThresholdComparatorGT, Value: 5,
+		Severity: domain.ThresholdSeverityWarning, Enabled: true,
+	}}, nil)
+	m.pg.On("ListIngestionFilterRules", mock.Anything, fixedTenantID).Return([]domain.IngestionFilterRule{}, nil)
+	m.pg.On("GetHealthProfile", mock.Anything, fixedTenantID).Return(nil, fmt.Errorf("postgres: health profile not found: %s", fixedTenantID))
+	m.pg.On("ListTenantSavedSearches", mock.Anything, fixedTenantID).Return([]domain.SavedSearch{}, nil)
+}
+
+func TestTenantConfigHandlers_Export(t *testing.T) {
+	m := newHandlerMocks()
+	expectTenantConfig(m)
+	h := NewTenantConfigHandlers(m.pg, tenantconfig.NewManager(tenantconfig.DefaultAdapters(m.pg)...))
+
+	rr := newTestRequest(http.MethodGet, "/api/v1/tenants/"+fixedTenantID.String()+"/config-export").
+		vars("tenant_id", fixedTenantID.String()).serve(h.Export())
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/yaml", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "tenant-config-"+fixedTenantID.String()+".yaml")
+	body := rr.Body.String()
+	assert.True(t, strings.HasPrefix(body, "api_version: remedyiq/v1\nkind: TenantConfig\n"), body)
+	assert.Contains(t, body, "- name: errors")
+	assert.Contains(t, body, "ingestion_filters: []")
+	m.assertExpectations(t)
+}
+
+func TestTenantConfigHandlers_Import(t *testing.T) {
+	path := "/api/v1/tenants/" + fixedTenantID.String() + "/config-import"
+	doc := `api_version: remedyiq/v1
+kind: TenantConfig
+threshold_rules:
+  - name: errors
+    scope: global
+    metric: error_rate
+    comparator: gt
+    value: 10
+`
+
+	t.Run("dry run returns the diff", func(t *testing.T) {
+		m := newHandlerMocks()
+		expectTenantConfig(m)
+		h := NewTenantConfigHandlers(m.pg, tenantconfig.NewManager(tenantconfig.DefaultAdapters(m.pg)...))
+
+		rr := newTestRequest(http.MethodPut, path+"?dry_run=true").rawBody(doc).
+			vars("tenant_id", fixedTenantID.String()).serve(h.Import())
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var resp struct {
+			DryRun  bool `json:"dry_run"`
+			Changes []struct {
+				Section string         `json:"section"`
+				Key     string         `json:"key"`
+				Action  string         `json:"action"`
+				Before  map[string]any `json:"before"`
+				After   map[string]any `json:"after"`
+			} `json:"changes"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.True(t, resp.DryRun)
+		require.Len(t, resp.Changes, 1)
+		assert.Equal(t, "update", resp.Changes[0].Action)
+		assert.Equal(t, "threshold_rules", resp.Changes[0].Section)
+		assert.Equal(t, 5.0, resp.Changes[0].Before["value"])
+		assert.Equal(t, 10.0, resp.Changes[0].After["value"])
+		m.pg.AssertNotCalled(t, "UpdateThresholdRule", mock.Anything, mock.Anything)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		m := newHandlerMocks()
+		expectTenantConfig(m)
+		m.pg.On("UpdateThresholdRule", mock.Anything, mock.MatchedBy(func(r *domain.ThresholdRule) bool {
+			return r.Name == "errors" && r.Value == 10 && r.TenantID == fixedTenantID
+		})).Return(nil)
+		h := NewTenantConfigHandlers(m.pg, tenantconfig.NewManager(tenantconfig.DefaultAdapters(m.pg)...))
+
+		rr := newTestRequest(http.MethodPut, path).rawBody(doc).tenant(fixedTenantID.String()).user("admin-1").
+			vars("tenant_id", fixedTenantID.String()).serve(h.Import())
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), `"applied":[{"section":"threshold_rules","key":"errors","action":"update"`)
+		m.pg.AssertCalled(t, "UpdateThresholdRule", mock.Anything, mock.Anything)
+		// Sections missing from the document are not read without prune.
+		m.pg.AssertNotCalled(t, "ListIngestionFilterRules", mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed section", func(t *testing.T) {
+		m := newHandlerMocks()
+		expectTenantConfig(m)
+		m.pg.On("UpdateThresholdRule", mock.Anything, mock.Anything).Return(fmt.Errorf("postgres: update threshold rule: timeout"))
+		h := NewTenantConfigHandlers(m.pg, tenantconfig.NewManager(tenantconfig.DefaultAdapters(m.pg)...))
+
+		rr := newTestRequest(http.MethodPut, path).rawBody(doc).
+			vars("tenant_id", fixedTenantID.String()).serve(h.Import())
+		require.Equal(t, http.StatusInternalServerError, rr.Code)
+		var resp api.ErrorResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, "failed to apply threshold_rules; its changes were rolled back", resp.Message)
+		assert.Contains(t, rr.Body.String(), `"failed":{"section":"threshold_rules","key":"errors"`)
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetTenant", mock.Anything, fixedTenantID).Return(&domain.Tenant{ID: fixedTenantID}, nil)
+		h := NewTenantConfigHandlers(m.pg, tenantconfig.NewManager(tenantconfig.DefaultAdapters(m.pg)...))
+
+		rr := newTestRequest(http.MethodPut, path).rawBody(strings.Replace(doc, "value: 10", "value: 10\n    colour: red", 1)).
+			vars("tenant_id", fixedTenantID.String()).serve(h.Import())
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		var resp struct {
+			Details []tenantconfig.ValidationError `json:"details"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Len(t, resp.Details, 1)
+		assert.Equal(t, tenantconfig.ValidationError{Path: "threshold_rules[0].colour", Line: 9, Column: 5, Message: "unknown field"}, resp.Details[0])
+	})
+
+	t.Run("invalid flag", func(t *testing.T) {
+		rr := newTestRequest(http.MethodPut, path+"?prune=maybe").rawBody(doc).
+			vars("tenant_id", fixedTenantID.String()).serve(NewTenantConfigHandlers(nil, nil).Import())
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("tenant not found", func(t *testing.T) {
+		m := newHandlerMocks()
+		m.pg.On("GetTenant", mock.Anything, fixedTenantID).Return(nil, fmt.Errorf("postgres: tenant not found: %s", fixedTenantID))
+		rr := newTestRequest(http.MethodPut, path).rawBody(doc).
+			vars("tenant_id", fixedTenantID.String()).serve(NewTenantConfigHandlers(m.pg, nil).Import())
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
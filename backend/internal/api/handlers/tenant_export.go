@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+// tenantExportRequest is the optional body of POST /api/v1/tenants/{tenant_id}/export.
+type tenantExportRequest struct {
+	IncludeEntries        bool     `json:"include_entries"`
+	EntryJobIDs           []string `json:"entry_job_ids"`
+	MaxEntriesPerAnalysis int64    `json:"max_entries_per_analysis"`
+}
+
+// TenantExportHandlers let administrators export everything stored for a
+// tenant as a tar.gz bundle with a versioned manifest, for migrations and
+// offboarding. Bundles are built by the worker like search exports.
+type TenantExportHandlers struct {
+	pg        storage.PostgresStore
+	nats      streaming.NATSStreamer
+	s3        storage.ObjectStorage
+	urlExpiry time.Duration
+	retention time.Duration
+
+	stamps
+}
+
+// NewTenantExportHandlers creates the tenant export handlers. urlExpiry is
+// the lifetime of generated download URLs and retention is how long
+// bundles are kept before cleanup.
+func NewTenantExportHandlers(pg storage.PostgresStore, nats streaming.NATSStreamer, s3 storage.ObjectStorage, urlExpiry, retention time.Duration) *TenantExportHandlers {
+	return &TenantExportHandlers{pg: pg, nats: nats, s3: s3, urlExpiry: urlExpiry, retention: retention}
+}
+
+// CreateExport handles POST /api/v1/tenants/{tenant_id}/export. Log entries
+// are only bundled when include_entries is set, optionally for the analyses
+// in entry_job_ids and capped per analysis by max_entries_per_analysis.
+func (h *TenantExportHandlers) CreateExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := pathID(w, r, "tenant_id")
+		if !ok {
+			return
+		}
+
+		var req tenantExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		if req.MaxEntriesPerAnalysis < 0 {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "max_entries_per_analysis must not be negative")
+			return
+		}
+		q := domain.TenantExportQuery{IncludeEntries: req.IncludeEntries, MaxEntriesPerAnalysis: req.MaxEntriesPerAnalysis}
+		for _, id := range req.EntryJobIDs {
+			jobID, ok := bodyID(w, "entry_job_ids", id)
+			if !ok {
+				return
+			}
+			q.EntryJobIDs = append(q.EntryJobIDs, jobID)
+		}
+
+		if _, err := h.pg.GetTenant(r.Context(), tid); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+			} else {
+				slog.Error("failed to load tenant for export", "tenant_id", tid, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to load tenant")
+			}
+			return
+		}
+
+		query, err := json.Marshal(q)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid query")
+			return
+		}
+
+		export := &domain.SearchExport{
+			ID:        h.newID(),
+			TenantID:  tid,
+			UserID:    middleware.GetUserID(r.Context()),
+			Status:    domain.ExportStatusQueued,
+			Format:    domain.ExportFormatTenantBundle,
+			Query:     query,
+			ExpiresAt: h.now().Add(h.retention),
+		}
+
+		if err := h.pg.CreateSearchExport(r.Context(), export); err != nil {
+			slog.Error("failed to create tenant export", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create export")
+			return
+		}
+
+		if err := h.nats.PublishExportSubmit(r.Context(), tid.String(), *export); err != nil {
+			errMsg := "failed to queue export: " + err.Error()
+			if updateErr := h.pg.UpdateSearchExportStatus(r.Context(), tid, export.ID, domain.ExportStatusFailed, &errMsg); updateErr != nil {
+				slog.Error("failed to update export status after NATS publish failure",
+					"export_id", export.ID, "tenant_id", tid, "error", updateErr)
+			}
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to queue export")
+			return
+		}
+
+		slog.Info("tenant export queued", "tenant_id", tid, "export_id", export.ID,
+			"requested_by", export.UserID, "include_entries", q.IncludeEntries)
+		api.JSON(w, http.StatusAccepted, export)
+	})
+}
+
+// GetExport handles GET /api/v1/tenants/{tenant_id}/exports/{export_id}.
+// Once the bundle is complete the response carries a download URL; a
+// pre-signed one comes with its expiry.
+func (h *TenantExportHandlers) GetExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		export, ok := h.lookup(w, r)
+		if !ok {
+			return
+		}
+		// The bundle lives in the exported tenant's region, not the caller's.
+		r = r.WithContext(storage.WithTenant(r.Context(), export.TenantID.String()))
+
+		apiPath := "/api/v1/tenants/" + export.TenantID.String() + "/exports/" + export.ID.String() + "/download"
+		if err := setDownloadURL(r, h.s3, export, h.urlExpiry, apiPath); err != nil {
+			slog.Error("failed to presign export URL", "export_id", export.ID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to generate download URL")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, export)
+	})
+}
+
+// DownloadExport handles GET /api/v1/tenants/{tenant_id}/exports/{export_id}/download,
+// the download URL of bundles on storage backends that cannot pre-sign URLs.
+func (h *TenantExportHandlers) DownloadExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		export, ok := h.lookup(w, r)
+		if !ok {
+			return
+		}
+		// The bundle lives in the exported tenant's region, not the caller's.
+		r = r.WithContext(storage.WithTenant(r.Context(), export.TenantID.String()))
+		serveExportObject(w, r, h.s3, export)
+	})
+}
+
+// lookup loads the bundle named by the tenant_id and export_id path
+// variables, writing the error response when there is none.
+func (h *TenantExportHandlers) lookup(w http.ResponseWriter, r *http.Request) (*domain.SearchExport, bool) {
+	tid, ok := pathID(w, r, "tenant_id")
+	if !ok {
+		return nil, false
+	}
+	exportID, ok := pathID(w, r, "export_id")
+	if !ok {
+		return nil, false
+	}
+
+	export, err := h.pg.GetSearchExport(r.Context(), tid, exportID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "export not found")
+		} else {
+			slog.Error("failed to retrieve tenant export", "export_id", exportID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve export")
+		}
+		return nil, false
+	}
+	// Search exports of the tenant are only visible to its own users.
+	if export.Format != domain.ExportFormatTenantBundle {
+		api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "export not found")
+		return nil, false
+	}
+	return export, true
+}
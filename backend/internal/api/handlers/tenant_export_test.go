@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestTenantExportHandlers_CreateExport(t *testing.T) {
+	// The exported tenant differs from the administrator's own.
+	target := fixedJobID
+	entryJob := uuid.MustParse("00000000-0000-0000-0000-000000000020")
+
+	tests := []struct {
+		name       string
+		tenant     string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "queues a bundle of the requested tenant",
+			tenant: target.String(),
+			body:   `{"include_entries":true,"entry_job_ids":["` + entryJob.String() + `"],"max_entries_per_analysis":1000}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetTenant", mock.Anything, target).Return(&domain.Tenant{ID: target}, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					var q domain.TenantExportQuery
+					return e.TenantID == target && e.JobID == uuid.Nil && e.UserID == "test-user" &&
+						e.Format == domain.ExportFormatTenantBundle && e.Status == domain.ExportStatusQueued &&
+						json.Unmarshal(e.Query, &q) == nil && q.IncludeEntries &&
+						len(q.EntryJobIDs) == 1 && q.EntryJobIDs[0] == entryJob && q.MaxEntriesPerAnalysis == 1000
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, target.String(), mock.AnythingOfType("domain.SearchExport")).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.SearchExport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, domain.ExportFormatTenantBundle, resp.Format)
+				assert.Equal(t, target, resp.TenantID)
+			},
+		},
+		{
+			name:   "empty body exports metadata only",
+			tenant: target.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetTenant", mock.Anything, target).Return(&domain.Tenant{ID: target}, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.MatchedBy(func(e *domain.SearchExport) bool {
+					var q domain.TenantExportQuery
+					return json.Unmarshal(e.Query, &q) == nil && !q.IncludeEntries
+				})).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, target.String(), mock.Anything).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "invalid entry job ID",
+			tenant:     target.String(),
+			body:       `{"include_entries":true,"entry_job_ids":["nope"]}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative entry cap",
+			tenant:     target.String(),
+			body:       `{"max_entries_per_analysis":-1}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid tenant id",
+			tenant:     "nope",
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown tenant",
+			tenant: target.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetTenant", mock.Anything, target).Return(nil, fmt.Errorf("postgres: tenant not found: %s", target))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "publish failure marks the export failed",
+			tenant: target.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ns *testutil.MockNATSStreamer) {
+				pg.On("GetTenant", mock.Anything, target).Return(&domain.Tenant{ID: target}, nil)
+				pg.On("CreateSearchExport", mock.Anything, mock.Anything).Return(nil)
+				ns.On("PublishExportSubmit", mock.Anything, target.String(), mock.Anything).Return(errors.New("nats down"))
+				pg.On("UpdateSearchExportStatus", mock.Anything, target, mock.Anything, domain.ExportStatusFailed, mock.Anything).Return(nil)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ns := new(testutil.MockNATSStreamer)
+			tc.setupMocks(pg, ns)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tenants/"+tc.tenant+"/export", strings.NewReader(tc.body))
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"tenant_id": tc.tenant})
+			w := httptest.NewRecorder()
+			h := NewTenantExportHandlers(pg, ns, new(testutil.MockObjectStorage), testExportURLExpiry, testExportRetention)
+			h.CreateExport().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			ns.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTenantExportHandlers_GetExport(t *testing.T) {
+	target := fixedJobID
+	exportID := uuid.MustParse("00000000-0000-0000-0000-000000000010")
+	key := "tenants/" + target.String() + "/exports/" + exportID.String() + ".tar.gz"
+
+	tests := []struct {
+		name       string
+		export     *domain.SearchExport
+		wantStatus int
+		wantURL    bool
+	}{
+		{
+			name: "complete bundle includes pre-signed URL",
+			export: &domain.SearchExport{ID: exportID, TenantID: target, Format: domain.ExportFormatTenantBundle,
+				Status: domain.ExportStatusComplete, S3Key: &key},
+			wantStatus: http.StatusOK,
+			wantURL:    true,
+		},
+		{
+			name: "running bundle",
+			export: &domain.SearchExport{ID: exportID, TenantID: target, Format: domain.ExportFormatTenantBundle,
+				Status: domain.ExportStatusRunning, ProgressPct: 40},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "search exports are not served",
+			export: &domain.SearchExport{ID: exportID, TenantID: target, JobID: fixedJobID, Format: "csv",
+				Status: domain.ExportStatusComplete, S3Key: &key},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			s3 := new(testutil.MockObjectStorage)
+			pg.On("GetSearchExport", mock.Anything, target, exportID).Return(tc.export, nil)
+			if tc.wantURL {
+				s3.On("PresignGetURL", mock.Anything, key, testExportURLExpiry).Return("https://s3.example/bundle", nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/tenants/"+target.String()+"/exports/"+exportID.String(), nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()),
+				map[string]string{"tenant_id": target.String(), "export_id": exportID.String()})
+			w := httptest.NewRecorder()
+			NewTenantExportHandlers(pg, new(testutil.MockNATSStreamer), s3, testExportURLExpiry, testExportRetention).
+				GetExport().ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusOK {
+				var resp domain.SearchExport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				if tc.wantURL {
+					assert.Equal(t, "https://s3.example/bundle", resp.DownloadURL)
+					assert.NotNil(t, resp.URLExpiresAt)
+				} else {
+					assert.Empty(t, resp.DownloadURL)
+				}
+			}
+			s3.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTenantExportHandlers_DownloadExport(t *testing.T) {
+	target := fixedJobID
+	exportID := uuid.MustParse("00000000-0000-0000-0000-000000000010")
+	key := "tenants/" + target.String() + "/exports/" + exportID.String() + ".tar.gz"
+
+	pg := new(testutil.MockPostgresStore)
+	s3 := new(testutil.MockObjectStorage)
+	pg.On("GetSearchExport", mock.Anything, target, exportID).Return(&domain.SearchExport{
+		ID: exportID, TenantID: target, Format: domain.ExportFormatTenantBundle,
+		Status: domain.ExportStatusComplete, S3Key: &key,
+	}, nil)
+	s3.On("Download", mock.Anything, key).Return(io.NopCloser(strings.NewReader("bundle")), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tenants/"+target.String()+"/exports/"+exportID.String()+"/download", nil)
+	req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()),
+		map[string]string{"tenant_id": target.String(), "export_id": exportID.String()})
+	w := httptest.NewRecorder()
+	NewTenantExportHandlers(pg, new(testutil.MockNATSStreamer), s3, testExportURLExpiry, testExportRetention).
+		DownloadExport().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bundle", w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), exportID.String()+".tar.gz")
+	s3.AssertExpectations(t)
+}
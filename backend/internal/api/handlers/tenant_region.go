@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// RegionRegistry is the set of configured data residency regions.
+// storage.Regions implements it.
+type RegionRegistry interface {
+	Known(region string) bool
+	SetTenantRegion(tenantID, region string)
+	Health() []storage.RegionHealth
+}
+
+// tenantRegionRequest is the body of PUT .../region. The empty region is
+// the default storage.
+type tenantRegionRequest struct {
+	Region string `json:"region"`
+}
+
+// TenantRegionHandlers pin tenants to a data residency region and report
+// the health of the regions' storage.
+type TenantRegionHandlers struct {
+	pg      storage.PostgresStore
+	regions RegionRegistry
+}
+
+func NewTenantRegionHandlers(pg storage.PostgresStore, regions RegionRegistry) *TenantRegionHandlers {
+	return &TenantRegionHandlers{pg: pg, regions: regions}
+}
+
+// SetRegion handles PUT /api/v1/admin/tenants/{tenant_id}/region. A tenant
+// with no data yet moves at once. Once it has uploads or analyses, its
+// ClickHouse data must first be copied to the region's cluster with
+// migrate-tenant; the change is refused with 409 until a migration there
+// has completed.
+func (h *TenantRegionHandlers) SetRegion() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ok := pathID(w, r, "tenant_id")
+		if !ok {
+			return
+		}
+		var req tenantRegionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		if !h.regions.Known(req.Region) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "unknown region: "+req.Region)
+			return
+		}
+
+		tenant, err := h.pg.GetTenant(r.Context(), tenantID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+			} else {
+				slog.Error("get tenant failed", "tenant_id", tenantID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve tenant")
+			}
+			return
+		}
+		if tenant.Region == req.Region {
+			api.JSON(w, http.StatusOK, tenant)
+			return
+		}
+
+		hasData, err := h.pg.TenantHasData(r.Context(), tenantID)
+		if err != nil {
+			slog.Error("check tenant data failed", "tenant_id", tenantID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to check tenant data")
+			return
+		}
+		if hasData {
+			cluster := req.Region
+			if cluster == "" {
+				cluster = storage.DefaultClickHouseCluster
+			}
+			migration, err := h.pg.GetLastCompletedTenantMigration(r.Context(), tenantID)
+			if err != nil && !storage.IsNotFound(err) {
+				slog.Error("get tenant migration failed", "tenant_id", tenantID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve tenant migration")
+				return
+			}
+			if migration == nil || migration.TargetCluster != cluster {
+				api.Error(w, http.StatusConflict, api.ErrCodeConflict,
+					"tenant has data; migrate it to cluster "+cluster+" with migrate-tenant before changing its region")
+				return
+			}
+		}
+
+		if err := h.pg.SetTenantRegion(r.Context(), tenantID, req.Region); err != nil {
+			slog.Error("set tenant region failed", "tenant_id", tenantID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to set tenant region")
+			return
+		}
+		h.regions.SetTenantRegion(tenantID.String(), req.Region)
+		slog.Info("tenant region changed", "tenant_id", tenantID, "from", tenant.Region, "to", req.Region)
+
+		tenant.Region = req.Region
+		api.JSON(w, http.StatusOK, tenant)
+	})
+}
+
+// ListRegions handles GET /api/v1/admin/regions: the configured regions
+// and the health of their storage.
+func (h *TenantRegionHandlers) ListRegions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.JSON(w, http.StatusOK, map[string]any{"regions": h.regions.Health()})
+	})
+}
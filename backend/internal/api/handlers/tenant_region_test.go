@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// fakeRegions is a RegionRegistry of the "eu" region.
+type fakeRegions struct {
+	set map[string]string
+}
+
+func (f *fakeRegions) Known(region string) bool { return region == "" || region == "eu" }
+
+func (f *fakeRegions) SetTenantRegion(tenantID, region string) {
+	if f.set == nil {
+		f.set = make(map[string]string)
+	}
+	f.set[tenantID] = region
+}
+
+func (f *fakeRegions) Health() []storage.RegionHealth {
+	return []storage.RegionHealth{{Region: "eu", ObjectStorage: storage.ServiceHealth{Status: "not_connected"}, ClickHouse: storage.ServiceHealth{Status: "ok"}}}
+}
+
+func TestTenantRegionHandlers_SetRegion(t *testing.T) {
+	tenant := func(region string) *domain.Tenant {
+		return &domain.Tenant{ID: fixedTenantID, Name: "Acme", Region: region}
+	}
+	notFound := fmt.Errorf("postgres: completed tenant migration not found: %s", fixedTenantID)
+
+	tests := []struct {
+		name       string
+		region     string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		wantSet    bool
+	}{
+		{
+			name:   "tenant without data moves at once",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(""), nil)
+				pg.On("TenantHasData", mock.Anything, fixedTenantID).Return(false, nil)
+				pg.On("SetTenantRegion", mock.Anything, fixedTenantID, "eu").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantSet:    true,
+		},
+		{
+			name:   "tenant with data and no migration",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(""), nil)
+				pg.On("TenantHasData", mock.Anything, fixedTenantID).Return(true, nil)
+				pg.On("GetLastCompletedTenantMigration", mock.Anything, fixedTenantID).Return(nil, notFound)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "tenant with data migrated elsewhere",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(""), nil)
+				pg.On("TenantHasData", mock.Anything, fixedTenantID).Return(true, nil)
+				pg.On("GetLastCompletedTenantMigration", mock.Anything, fixedTenantID).
+					Return(&domain.TenantMigration{TargetCluster: "apac"}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "tenant with data migrated to the region",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(""), nil)
+				pg.On("TenantHasData", mock.Anything, fixedTenantID).Return(true, nil)
+				pg.On("GetLastCompletedTenantMigration", mock.Anything, fixedTenantID).
+					Return(&domain.TenantMigration{TargetCluster: "eu", Status: domain.TenantMigrationCompleted}, nil)
+				pg.On("SetTenantRegion", mock.Anything, fixedTenantID, "eu").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantSet:    true,
+		},
+		{
+			name:   "back to the default storage after migrating to the default cluster",
+			region: "",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant("eu"), nil)
+				pg.On("TenantHasData", mock.Anything, fixedTenantID).Return(true, nil)
+				pg.On("GetLastCompletedTenantMigration", mock.Anything, fixedTenantID).
+					Return(&domain.TenantMigration{TargetCluster: storage.DefaultClickHouseCluster}, nil)
+				pg.On("SetTenantRegion", mock.Anything, fixedTenantID, "").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantSet:    true,
+		},
+		{
+			name:   "unchanged region",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant("eu"), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown region",
+			region:     "apac",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "tenant not found",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(nil, fmt.Errorf("postgres: tenant not found: %s", fixedTenantID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "data check fails",
+			region: "eu",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenant", mock.Anything, fixedTenantID).Return(tenant(""), nil)
+				pg.On("TenantHasData", mock.Anything, fixedTenantID).Return(false, errors.New("connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			if tt.setupMocks != nil {
+				tt.setupMocks(pg)
+			}
+			regions := &fakeRegions{}
+			h := NewTenantRegionHandlers(pg, regions)
+
+			w := newTestRequest(http.MethodPut, "/api/v1/admin/tenants/"+fixedTenantID.String()+"/region").
+				vars("tenant_id", fixedTenantID.String()).
+				jsonBody(t, map[string]string{"region": tt.region}).
+				serve(h.SetRegion())
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if w.Code == http.StatusOK {
+				var got domain.Tenant
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+				assert.Equal(t, tt.region, got.Region)
+			}
+			if tt.wantSet {
+				assert.Equal(t, map[string]string{fixedTenantID.String(): tt.region}, regions.set)
+			} else {
+				assert.Empty(t, regions.set)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTenantRegionHandlers_InvalidRequest(t *testing.T) {
+	h := NewTenantRegionHandlers(new(testutil.MockPostgresStore), &fakeRegions{})
+
+	w := newTestRequest(http.MethodPut, "/").vars("tenant_id", "nope").jsonBody(t, map[string]string{"region": "eu"}).serve(h.SetRegion())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := newTestRequest(http.MethodPut, "/").vars("tenant_id", fixedTenantID.String())
+	req.body = nil
+	w = req.serve(h.SetRegion())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTenantRegionHandlers_ListRegions(t *testing.T) {
+	h := NewTenantRegionHandlers(new(testutil.MockPostgresStore), &fakeRegions{})
+
+	w := newTestRequest(http.MethodGet, "/api/v1/admin/regions").serve(h.ListRegions())
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Regions []storage.RegionHealth `json:"regions"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	require.Len(t, body.Regions, 1)
+	assert.Equal(t, "eu", body.Regions[0].Region)
+	assert.Equal(t, "not_connected", body.Regions[0].ObjectStorage.Status)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// TenantRetentionHandler serves GET /api/v1/admin/tenants/retention: each
+// tenant's effective retention and current ClickHouse storage use. Sandbox
+// tenants are left out unless include_sandbox=true.
+type TenantRetentionHandler struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+func NewTenantRetentionHandler(pg storage.PostgresStore, ch storage.ClickHouseStore) *TenantRetentionHandler {
+	return &TenantRetentionHandler{pg: pg, ch: ch}
+}
+
+func (h *TenantRetentionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.pg.ListTenants(r.Context())
+	if err != nil {
+		slog.Error("list tenants failed", "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list tenants")
+		return
+	}
+
+	usage, err := h.ch.GetTenantStorage(r.Context())
+	if err != nil {
+		slog.Error("tenant storage query failed", "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to query tenant storage")
+		return
+	}
+	byTenant := make(map[string]domain.TenantStorage, len(usage))
+	for _, u := range usage {
+		byTenant[u.TenantID] = u
+	}
+
+	includeSandbox := r.URL.Query().Get("include_sandbox") == "true"
+	out := make([]domain.TenantRetention, 0, len(tenants))
+	for _, t := range tenants {
+		if t.Sandbox && !includeSandbox {
+			continue
+		}
+		// The effective class is the one stored rows carry; Pending marks
+		// a plan change the reconciler has not applied yet.
+		policy := storage.RetentionPolicies[t.RetentionClass]
+		u := byTenant[t.ID.String()]
+		bytesByDisk := u.BytesByDisk
+		if bytesByDisk == nil {
+			bytesByDisk = map[string]uint64{}
+		}
+		out = append(out, domain.TenantRetention{
+			TenantID:       t.ID,
+			Name:           t.Name,
+			Plan:           t.Plan,
+			RetentionClass: t.RetentionClass,
+			Pending:        storage.RetentionClassForTenant(&t) != t.RetentionClass,
+			Sandbox:        t.Sandbox,
+			HotDays:        policy.HotDays,
+			RetentionDays:  policy.RetentionDays,
+			Rows:           u.Rows,
+			Bytes:          u.Bytes,
+			BytesByDisk:    bytesByDisk,
+		})
+	}
+
+	api.JSON(w, http.StatusOK, map[string]interface{}{
+		"tenants": out,
+		"total":   len(out),
+	})
+}
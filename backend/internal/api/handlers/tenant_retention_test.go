@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestTenantRetentionHandler(t *testing.T) {
+	tenants := []domain.Tenant{
+		{ID: fixedTenantID, Name: "Acme", Plan: "enterprise", RetentionClass: domain.RetentionStandard},
+		{ID: fixedJobID, Name: "Trialco", Plan: "trial", RetentionClass: domain.RetentionTrial},
+		{ID: fixedFileID, Name: "Zeta demo", Plan: "enterprise", RetentionClass: domain.RetentionTrial, Sandbox: true},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		setupMocks func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "joins tenants with storage use",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("ListTenants", mock.Anything).Return(tenants, nil)
+				ch.On("GetTenantStorage", mock.Anything).Return([]domain.TenantStorage{
+					{TenantID: fixedTenantID.String(), Rows: 25, Bytes: 250, BytesByDisk: map[string]uint64{"default": 200, "cold": 50}},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp struct {
+					Tenants []domain.TenantRetention `json:"tenants"`
+					Total   int                      `json:"total"`
+				}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				require.Equal(t, 2, resp.Total, "sandbox tenants are left out")
+
+				acme := resp.Tenants[0]
+				assert.Equal(t, domain.RetentionStandard, acme.RetentionClass)
+				assert.True(t, acme.Pending, "enterprise plan not yet applied")
+				assert.Equal(t, 30, acme.HotDays)
+				assert.Equal(t, 90, acme.RetentionDays)
+				assert.Equal(t, uint64(250), acme.Bytes)
+				assert.Equal(t, uint64(50), acme.BytesByDisk["cold"])
+
+				trial := resp.Tenants[1]
+				assert.False(t, trial.Pending)
+				assert.Equal(t, 14, trial.RetentionDays)
+				assert.Zero(t, trial.Rows)
+				assert.NotNil(t, trial.BytesByDisk)
+			},
+		},
+		{
+			name:  "sandbox tenants on request",
+			query: "?include_sandbox=true",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("ListTenants", mock.Anything).Return(tenants, nil)
+				ch.On("GetTenantStorage", mock.Anything).Return([]domain.TenantStorage{}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp struct {
+					Tenants []domain.TenantRetention `json:"tenants"`
+				}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				require.Len(t, resp.Tenants, 3)
+
+				demo := resp.Tenants[2]
+				assert.True(t, demo.Sandbox)
+				assert.False(t, demo.Pending, "a sandbox keeps trial retention whatever its plan")
+				assert.Equal(t, 14, demo.RetentionDays)
+			},
+		},
+		{
+			name: "tenant listing fails",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("ListTenants", mock.Anything).Return(nil, errors.New("connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "storage query fails",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("ListTenants", mock.Anything).Return(tenants, nil)
+				ch.On("GetTenantStorage", mock.Anything).Return(nil, errors.New("timeout"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			tc.setupMocks(pg, ch)
+
+			req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/admin/tenants/retention"+tc.query, nil), fixedTenantID.String())
+			w := httptest.NewRecorder()
+			NewTenantRetentionHandler(pg, ch).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+		})
+	}
+}
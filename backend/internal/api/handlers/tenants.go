@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// tenantCreateRequest is the body of POST /api/v1/admin/tenants. A sandbox
+// must set when it expires.
+type tenantCreateRequest struct {
+	ClerkOrgID       string     `json:"clerk_org_id"`
+	Name             string     `json:"name"`
+	Plan             string     `json:"plan"`
+	StorageLimitGB   int        `json:"storage_limit_gb"`
+	Sandbox          bool       `json:"sandbox"`
+	SandboxExpiresAt *time.Time `json:"sandbox_expires_at,omitempty"`
+}
+
+// TenantAdminHandlers create tenants, sandboxes among them, and convert
+// sandboxes to full tenants.
+type TenantAdminHandlers struct {
+	pg  storage.PostgresStore
+	ch  storage.ClickHouseStore
+	now func() time.Time
+}
+
+func NewTenantAdminHandlers(pg storage.PostgresStore, ch storage.ClickHouseStore) *TenantAdminHandlers {
+	return &TenantAdminHandlers{pg: pg, ch: ch, now: time.Now}
+}
+
+// CreateTenant handles POST /api/v1/admin/tenants. A sandbox expires at
+// most domain.SandboxMaxLifetime from now; its data is then purged and the
+// tenant archived.
+func (h *TenantAdminHandlers) CreateTenant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req tenantCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		req.ClerkOrgID = strings.TrimSpace(req.ClerkOrgID)
+		req.Name = strings.TrimSpace(req.Name)
+		if req.ClerkOrgID == "" || req.Name == "" {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "clerk_org_id and name are required")
+			return
+		}
+		if req.StorageLimitGB < 0 {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "storage_limit_gb must not be negative")
+			return
+		}
+
+		now := h.now().UTC()
+		if req.Sandbox {
+			if req.SandboxExpiresAt == nil {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "a sandbox requires sandbox_expires_at")
+				return
+			}
+			if !req.SandboxExpiresAt.After(now) || req.SandboxExpiresAt.After(now.Add(domain.SandboxMaxLifetime)) {
+				api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "sandbox_expires_at must be in the next 30 days")
+				return
+			}
+		} else if req.SandboxExpiresAt != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "sandbox_expires_at is only valid for a sandbox")
+			return
+		}
+
+		tenant := &domain.Tenant{
+			ClerkOrgID:     req.ClerkOrgID,
+			Name:           req.Name,
+			Plan:           req.Plan,
+			StorageLimitGB: req.StorageLimitGB,
+			Sandbox:        req.Sandbox,
+		}
+		if tenant.Plan == "" {
+			tenant.Plan = "free"
+		}
+		if tenant.StorageLimitGB == 0 {
+			tenant.StorageLimitGB = 10
+		}
+		if req.Sandbox {
+			expires := req.SandboxExpiresAt.UTC()
+			tenant.SandboxExpiresAt = &expires
+		}
+
+		if err := h.pg.CreateTenant(r.Context(), tenant); err != nil {
+			if errors.Is(err, storage.ErrAlreadyExists) {
+				api.Error(w, http.StatusConflict, api.ErrCodeConflict, "a tenant already exists for clerk_org_id "+req.ClerkOrgID)
+				return
+			}
+			slog.Error("create tenant failed", "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create tenant")
+			return
+		}
+		slog.Info("tenant created", "tenant_id", tenant.ID, "plan", tenant.Plan, "sandbox", tenant.Sandbox)
+		api.JSON(w, http.StatusCreated, tenant)
+	})
+}
+
+// GetTenant handles GET /api/v1/admin/tenants/{tenant_id}.
+func (h *TenantAdminHandlers) GetTenant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ok := pathID(w, r, "tenant_id")
+		if !ok {
+			return
+		}
+		tenant, err := h.pg.GetTenant(r.Context(), tenantID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+			} else {
+				slog.Error("get tenant failed", "tenant_id", tenantID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve tenant")
+			}
+			return
+		}
+		api.JSON(w, http.StatusOK, tenant)
+	})
+}
+
+// ConvertSandbox handles POST /api/v1/admin/tenants/{tenant_id}/convert:
+// a sandbox that has not expired becomes a full tenant, keeping its data.
+// Its stored entries move to the retention of its plan at once, so that
+// the short sandbox retention deletes none of them; should that fail, the
+// retention reconciler moves them on its next run.
+func (h *TenantAdminHandlers) ConvertSandbox() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ok := pathID(w, r, "tenant_id")
+		if !ok {
+			return
+		}
+
+		tenant, err := h.pg.ConvertSandboxTenant(r.Context(), tenantID, h.now().UTC())
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotSandbox):
+				api.Error(w, http.StatusConflict, api.ErrCodeConflict, "tenant is not a sandbox, or has expired")
+			case storage.IsNotFound(err):
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "tenant not found")
+			default:
+				slog.Error("convert sandbox tenant failed", "tenant_id", tenantID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to convert tenant")
+			}
+			return
+		}
+		slog.Info("sandbox tenant converted", "tenant_id", tenantID, "plan", tenant.Plan)
+
+		if want := storage.RetentionClassForTenant(tenant); want != tenant.RetentionClass {
+			logger := slog.With("tenant_id", tenantID.String(), "from", tenant.RetentionClass, "to", want)
+			if err := h.ch.UpdateTenantRetentionClass(storage.WithTenant(r.Context(), tenantID.String()), tenantID.String(), want); err != nil {
+				logger.Warn("retention restamp of converted sandbox failed", "error", err)
+			} else if err := h.pg.UpdateTenantRetentionClass(r.Context(), tenantID, want); err != nil {
+				logger.Warn("failed to record retention class", "error", err)
+			} else {
+				tenant.RetentionClass = want
+			}
+		}
+		api.JSON(w, http.StatusOK, tenant)
+	})
+}
+
+// lookupTenant returns the tenant, or nil when it has no record or cannot
+// be read: it is then held to the configured quotas only.
+func lookupTenant(ctx context.Context, pg storage.PostgresStore, tenantID uuid.UUID) *domain.Tenant {
+	tenant, err := pg.GetTenant(ctx, tenantID)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			slog.Warn("tenant lookup failed, applying configured quotas", "tenant_id", tenantID, "error", err)
+		}
+		return nil
+	}
+	return tenant
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+func TestTenantAdminHandlers_CreateTenant(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inWeek := now.Add(7 * 24 * time.Hour)
+	tooLate := now.Add(domain.SandboxMaxLifetime + time.Hour)
+
+	tests := []struct {
+		name       string
+		body       map[string]any
+		setupMocks func(m *handlerMocks)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "creates a sandbox",
+			body: map[string]any{"clerk_org_id": "org_demo", "name": "Demo", "sandbox": true, "sandbox_expires_at": inWeek},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("CreateTenant", mock.Anything, mock.MatchedBy(func(tn *domain.Tenant) bool {
+					return tn.Sandbox && tn.SandboxExpiresAt.Equal(inWeek) && tn.Plan == "free" && tn.StorageLimitGB == 10
+				})).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var got domain.Tenant
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+				assert.True(t, got.Sandbox)
+				require.NotNil(t, got.SandboxExpiresAt)
+				assert.True(t, got.SandboxExpiresAt.Equal(inWeek))
+			},
+		},
+		{
+			name: "creates a full tenant",
+			body: map[string]any{"clerk_org_id": "org_acme", "name": "Acme", "plan": "enterprise", "storage_limit_gb": 500},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("CreateTenant", mock.Anything, mock.MatchedBy(func(tn *domain.Tenant) bool {
+					return !tn.Sandbox && tn.SandboxExpiresAt == nil && tn.Plan == "enterprise" && tn.StorageLimitGB == 500
+				})).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "name is required",
+			body:       map[string]any{"clerk_org_id": "org_demo"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "sandbox without expiry",
+			body:       map[string]any{"clerk_org_id": "org_demo", "name": "Demo", "sandbox": true},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "sandbox expiring too late",
+			body:       map[string]any{"clerk_org_id": "org_demo", "name": "Demo", "sandbox": true, "sandbox_expires_at": tooLate},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "sandbox expired already",
+			body:       map[string]any{"clerk_org_id": "org_demo", "name": "Demo", "sandbox": true, "sandbox_expires_at": now.Add(-time.Minute)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "expiry of a full tenant",
+			body:       map[string]any{"clerk_org_id": "org_acme", "name": "Acme", "sandbox_expires_at": inWeek},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "tenant exists",
+			body: map[string]any{"clerk_org_id": "org_acme", "name": "Acme"},
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("CreateTenant", mock.Anything, mock.Anything).Return(storage.ErrAlreadyExists)
+			},
+			wantStatus: http.StatusConflict,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			if tc.setupMocks != nil {
+				tc.setupMocks(m)
+			}
+			h := NewTenantAdminHandlers(m.pg, m.ch)
+			h.now = func() time.Time { return now }
+
+			w := newTestRequest(http.MethodPost, "/api/v1/admin/tenants").
+				jsonBody(t, tc.body).
+				serve(h.CreateTenant())
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			m.assertExpectations(t)
+		})
+	}
+}
+
+func TestTenantAdminHandlers_ConvertSandbox(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		setupMocks func(m *handlerMocks)
+		wantStatus int
+		wantCode   string
+		wantClass  domain.RetentionClass
+	}{
+		{
+			name: "restamps the retention of the plan",
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("ConvertSandboxTenant", mock.Anything, fixedTenantID, now).Return(&domain.Tenant{
+					ID: fixedTenantID, Plan: "enterprise", RetentionClass: domain.RetentionTrial,
+				}, nil)
+				m.ch.On("UpdateTenantRetentionClass", mock.Anything, fixedTenantID.String(), domain.RetentionEnterprise).Return(nil)
+				m.pg.On("UpdateTenantRetentionClass", mock.Anything, fixedTenantID, domain.RetentionEnterprise).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantClass:  domain.RetentionEnterprise,
+		},
+		{
+			name: "a failed restamp is left to the reconciler",
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("ConvertSandboxTenant", mock.Anything, fixedTenantID, now).Return(&domain.Tenant{
+					ID: fixedTenantID, Plan: "enterprise", RetentionClass: domain.RetentionTrial,
+				}, nil)
+				m.ch.On("UpdateTenantRetentionClass", mock.Anything, fixedTenantID.String(), domain.RetentionEnterprise).
+					Return(errors.New("clickhouse down"))
+			},
+			wantStatus: http.StatusOK,
+			wantClass:  domain.RetentionTrial,
+		},
+		{
+			name: "not a sandbox",
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("ConvertSandboxTenant", mock.Anything, fixedTenantID, now).Return(nil, storage.ErrNotSandbox)
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   api.ErrCodeConflict,
+		},
+		{
+			name: "unknown tenant",
+			setupMocks: func(m *handlerMocks) {
+				m.pg.On("ConvertSandboxTenant", mock.Anything, fixedTenantID, now).
+					Return(nil, errors.New("postgres: tenant not found"))
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   api.ErrCodeNotFound,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			tc.setupMocks(m)
+			h := NewTenantAdminHandlers(m.pg, m.ch)
+			h.now = func() time.Time { return now }
+
+			w := newTestRequest(http.MethodPost, "/api/v1/admin/tenants/x/convert").
+				vars("tenant_id", fixedTenantID.String()).
+				serve(h.ConvertSandbox())
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.wantCode != "" {
+				assert.Equal(t, tc.wantCode, decodeError(t, w).Code)
+			} else {
+				var got domain.Tenant
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+				assert.Equal(t, tc.wantClass, got.RetentionClass)
+			}
+			m.assertExpectations(t)
+		})
+	}
+}
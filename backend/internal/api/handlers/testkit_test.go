@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// handlerMocks wires the storage mocks most handlers are built from.
+type handlerMocks struct {
+	pg    *testutil.MockPostgresStore
+	ch    *testutil.MockClickHouseStore
+	redis *testutil.MockRedisCache
+}
+
+func newHandlerMocks() *handlerMocks {
+	return &handlerMocks{
+		pg:    new(testutil.MockPostgresStore),
+		ch:    new(testutil.MockClickHouseStore),
+		redis: new(testutil.MockRedisCache),
+	}
+}
+
+func (m *handlerMocks) assertExpectations(t *testing.T) {
+	t.Helper()
+	m.pg.AssertExpectations(t)
+	m.ch.AssertExpectations(t)
+	m.redis.AssertExpectations(t)
+}
+
+// testRequest builds a request as the router and the auth middleware
+// would hand it to a handler:
+//
+//	w := newTestRequest(http.MethodGet, "/api/v1/analysis/x/dashboard/gaps").
+//		tenant(fixedTenantID.String()).
+//		vars("job_id", fixedJobID.String()).
+//		serve(h)
+type testRequest struct {
+	method, path string
+	body         io.Reader
+	tenantID     string
+	userID       string
+	orgRole      string
+	urlVars      map[string]string
+	headers      http.Header
+}
+
+func newTestRequest(method, path string) *testRequest {
+	return &testRequest{method: method, path: path, userID: "test-user", headers: http.Header{}}
+}
+
+// tenant authenticates the request for tenantID; without it the request
+// has no auth context at all.
+func (b *testRequest) tenant(tenantID string) *testRequest {
+	b.tenantID = tenantID
+	return b
+}
+
+func (b *testRequest) user(userID string) *testRequest {
+	b.userID = userID
+	return b
+}
+
+// role sets the caller's organization role.
+func (b *testRequest) role(role string) *testRequest {
+	b.orgRole = role
+	return b
+}
+
+// vars sets route variables from key, value pairs.
+func (b *testRequest) vars(kv ...string) *testRequest {
+	if b.urlVars == nil {
+		b.urlVars = map[string]string{}
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		b.urlVars[kv[i]] = kv[i+1]
+	}
+	return b
+}
+
+func (b *testRequest) header(key, value string) *testRequest {
+	b.headers.Set(key, value)
+	return b
+}
+
+// jsonBody sets v, encoded as JSON, as the request body.
+func (b *testRequest) jsonBody(t *testing.T, v any) *testRequest {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	b.body = bytes.NewReader(data)
+	b.headers.Set("Content-Type", "application/json")
+	return b
+}
+
+// rawBody sets data as the request body, sent as is.
+func (b *testRequest) rawBody(data string) *testRequest {
+	b.body = strings.NewReader(data)
+	return b
+}
+
+func (b *testRequest) build() *http.Request {
+	req := httptest.NewRequest(b.method, b.path, b.body)
+	for key, values := range b.headers {
+		req.Header[key] = values
+	}
+	if b.tenantID != "" {
+		ctx := middleware.WithTenantID(req.Context(), b.tenantID)
+		ctx = middleware.WithUserID(ctx, b.userID)
+		if b.orgRole != "" {
+			ctx = middleware.WithOrgRole(ctx, b.orgRole)
+		}
+		req = req.WithContext(ctx)
+	}
+	if b.urlVars != nil {
+		req = mux.SetURLVars(req, b.urlVars)
+	}
+	return req
+}
+
+// serve runs the request through h and returns the recorded response.
+func (b *testRequest) serve(h http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, b.build())
+	return w
+}
+
+// assertGoldenJSON compares body, indented, with the golden file
+// testdata/api_<name>.golden.json, rewriting the file first when the
+// tests run with -update-golden.
+func assertGoldenJSON(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var got bytes.Buffer
+	require.NoError(t, json.Indent(&got, body, "", "  "))
+	got.WriteByte('\n')
+
+	path := filepath.Join("..", "..", "..", "testdata", "api_"+name+".golden.json")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, got.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got.String())
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// defaultTimelineGapMS is how far apart two calls on a thread may be
+	// and still belong to the same busy segment.
+	defaultTimelineGapMS = 1000
+	maxTimelineGapMS     = 3_600_000
+
+	defaultTimelineThreads = 20
+	maxTimelineThreads     = 100
+)
+
+// ThreadTimelineHandler serves the busy segments of each thread of a job,
+// for a swimlane chart that shows convoys and hot threads over time.
+type ThreadTimelineHandler struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+func NewThreadTimelineHandler(pg storage.PostgresStore, ch storage.ClickHouseStore) *ThreadTimelineHandler {
+	return &ThreadTimelineHandler{pg: pg, ch: ch}
+}
+
+// ServeHTTP handles GET /api/v1/analyses/{job_id}/threads/timeline with
+// optional from and to (RFC3339 timestamps or capture expressions such as
+// capture_end-15m, defaulting to the job's time range), queue, gap_ms and
+// limit (busiest threads returned).
+func (h *ThreadTimelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return
+	}
+
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	q := domain.ThreadTimelineQuery{
+		Queue: params.Get("queue"),
+		GapMS: defaultTimelineGapMS,
+		Limit: defaultTimelineThreads,
+	}
+	if v := params.Get("gap_ms"); v != "" {
+		gap, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || gap <= 0 || gap > maxTimelineGapMS {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "gap_ms must be between 1 and 3600000")
+			return
+		}
+		q.GapMS = gap
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxTimelineThreads {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "limit must be between 1 and 100")
+			return
+		}
+		q.Limit = limit
+	}
+
+	bounds, ok := parseTimeBounds(w, params, "from", "to")
+	if !ok {
+		return
+	}
+	// Absolute bounds are checked before the job is read; relative ones
+	// once they are resolved against its time range.
+	if !bounds.relative() {
+		if from, to := bounds.resolve(nil); from != nil && to != nil && !from.Before(*to) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "from must be before to")
+			return
+		}
+	}
+
+	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		} else {
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+		}
+		return
+	}
+
+	if job.Status != domain.JobStatusComplete {
+		sectionNotComplete(w)
+		return
+	}
+
+	// The window is clamped to the job's entries; one entirely outside them
+	// is rejected rather than answered with an empty chart.
+	tRange, err := h.ch.GetJobTimeRange(r.Context(), tenantID, jobID.String())
+	if err != nil {
+		slog.Error("failed to get job time range for thread timeline", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to determine analysis time range")
+		return
+	}
+	from, to := bounds.resolve(tRange)
+	if from != nil && to != nil && !from.Before(*to) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "from must be before to")
+		return
+	}
+	q.From, q.To = tRange.Start, tRange.End
+	if from != nil && from.After(q.From) {
+		q.From = *from
+	}
+	if to != nil && to.Before(q.To) {
+		q.To = *to
+	}
+	if q.From.After(q.To) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "time window is outside the analysis time range")
+		return
+	}
+
+	threads, err := h.ch.GetThreadTimeline(r.Context(), tenantID, jobID.String(), q)
+	if err != nil {
+		slog.Error("failed to get thread timeline", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "thread timeline not available")
+		return
+	}
+	if threads == nil {
+		threads = []domain.ThreadTimeline{}
+	}
+
+	api.JSON(w, http.StatusOK, domain.ThreadTimelineResponse{
+		From:    domain.NewTimestamp(q.From),
+		To:      domain.NewTimestamp(q.To),
+		Queue:   q.Queue,
+		GapMS:   q.GapMS,
+		Threads: threads,
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestThreadTimelineHandler_ServeHTTP(t *testing.T) {
+	jobStart := time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC)
+	jobEnd := jobStart.Add(time.Hour)
+	completeJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+	timeline := []domain.ThreadTimeline{{
+		ThreadID:     "0000000123",
+		Queue:        "Fast",
+		TotalEntries: 3,
+		TotalMS:      900,
+		Segments: []domain.ThreadSegment{{
+			Start: domain.NewTimestamp(jobStart), End: domain.NewTimestamp(jobStart.Add(time.Second)), EntryCount: 3, DominantLogType: domain.LogTypeAPI,
+		}},
+	}}
+
+	tests := []struct {
+		name       string
+		query      string
+		setupMocks func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore)
+		wantStatus int
+		checkBody  func(t *testing.T, resp domain.ThreadTimelineResponse)
+	}{
+		{
+			name: "defaults to the job range and busiest threads",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+					Return(&storage.JobTimeRange{Start: jobStart, End: jobEnd}, nil)
+				ch.On("GetThreadTimeline", mock.Anything, fixedTenantID.String(), fixedJobID.String(), domain.ThreadTimelineQuery{
+					From: jobStart, To: jobEnd, GapMS: defaultTimelineGapMS, Limit: defaultTimelineThreads,
+				}).Return(timeline, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, resp domain.ThreadTimelineResponse) {
+				assert.Equal(t, int64(defaultTimelineGapMS), resp.GapMS)
+				require.Len(t, resp.Threads, 1)
+				assert.Equal(t, "0000000123", resp.Threads[0].ThreadID)
+				require.Len(t, resp.Threads[0].Segments, 1)
+				assert.Equal(t, int64(3), resp.Threads[0].Segments[0].EntryCount)
+			},
+		},
+		{
+			name:  "window is clamped to the job range",
+			query: "?from=2026-02-10T08:00:00Z&to=2026-02-10T09:30:00Z&queue=Fast&gap_ms=250&limit=5",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+					Return(&storage.JobTimeRange{Start: jobStart, End: jobEnd}, nil)
+				ch.On("GetThreadTimeline", mock.Anything, fixedTenantID.String(), fixedJobID.String(), domain.ThreadTimelineQuery{
+					From: jobStart, To: jobStart.Add(30 * time.Minute), Queue: "Fast", GapMS: 250, Limit: 5,
+				}).Return([]domain.ThreadTimeline{}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, resp domain.ThreadTimelineResponse) {
+				assert.Equal(t, "Fast", resp.Queue)
+				assert.True(t, resp.From.Equal(jobStart))
+				assert.NotNil(t, resp.Threads)
+			},
+		},
+		{
+			name:  "window outside the job range",
+			query: "?from=2026-02-11T00:00:00Z",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+					Return(&storage.JobTimeRange{Start: jobStart, End: jobEnd}, nil)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "from after to",
+			query:      "?from=2026-02-10T09:30:00Z&to=2026-02-10T09:00:00Z",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid gap",
+			query:      "?gap_ms=0",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "limit too large",
+			query:      "?limit=500",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid from",
+			query:      "?from=yesterday",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "job still running",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, Status: domain.JobStatusParsing}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "clickhouse failure",
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(completeJob, nil)
+				ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+					Return(&storage.JobTimeRange{Start: jobStart, End: jobEnd}, nil)
+				ch.On("GetThreadTimeline", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, errors.New("timeout"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			tc.setupMocks(pg, ch)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analyses/"+fixedJobID.String()+"/threads/timeline"+tc.query, nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+			w := httptest.NewRecorder()
+			NewThreadTimelineHandler(pg, ch).ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				var resp domain.ThreadTimelineResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				tc.checkBody(t, resp)
+			}
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+		})
+	}
+}
@@ -1,67 +1,39 @@
 package handlers
 
 import (
-	"net/http"
+	"context"
+	"log/slog"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
-	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 type ThreadsHandler struct {
-	pg    storage.PostgresStore
-	ch    storage.ClickHouseStore
-	redis storage.RedisCache
+	*sectionHandler[domain.ThreadStatsResponse]
 }
 
 func NewThreadsHandler(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache) *ThreadsHandler {
-	return &ThreadsHandler{pg: pg, ch: ch, redis: redis}
-}
-
-func (h *ThreadsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	tenantID := middleware.GetTenantID(r.Context())
-	if tenantID == "" {
-		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
-		return
-	}
-
-	jobIDStr := mux.Vars(r)["job_id"]
-	jobID, err := uuid.Parse(jobIDStr)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
-		return
-	}
-
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
-		return
+	h := newSectionHandler(pg, ch, redis, threadsSection)
+	h.decorate = func(ctx context.Context, job *domain.AnalysisJob, data any) {
+		addThreadCapacity(ctx, ch, job, data)
 	}
+	return &ThreadsHandler{h}
+}
 
-	job, err := h.pg.GetJob(r.Context(), tid, jobID)
+// addThreadCapacity compares the load of the job's queues with the threads
+// configured for them. The capacity is left out when the load cannot be
+// read.
+func addThreadCapacity(ctx context.Context, ch storage.ClickHouseStore, job *domain.AnalysisJob, data any) {
+	loads, err := ch.GetQueueLoad(ctx, job.TenantID.String(), job.ID.String())
 	if err != nil {
-		if storage.IsNotFound(err) {
-			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
-		} else {
-			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
-		}
-		return
-	}
-
-	if job.Status != domain.JobStatusComplete {
-		api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+		slog.Warn("threads: queue load not available", "job_id", job.ID, "error", err)
 		return
 	}
-
-	data, err := getOrComputeThreads(r.Context(), h.redis, tenantID, jobID.String())
-	if err != nil {
-		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "threads data not available")
-		return
+	capacity := storage.ThreadCapacityReport(loads, job.ThreadCounts)
+	switch threads := data.(type) {
+	case *domain.ThreadStatsResponse:
+		threads.Capacity = capacity
+	case *domain.JARThreadStatsResponse:
+		threads.Capacity = capacity
 	}
-
-	api.JSON(w, http.StatusOK, data)
 }
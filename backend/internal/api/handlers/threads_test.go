@@ -68,6 +68,12 @@ func TestThreadsHandler_ServeHTTP(t *testing.T) {
 	cachedJSON, err := json.Marshal(sampleResponse)
 	require.NoError(t, err)
 
+	// Two Fast threads busy 90 of the 120 thread-seconds of the peak minute.
+	queueLoads := []domain.QueueLoad{{
+		Queue: "Fast", ObservedThreads: 2, BusyMS: 180_000, SpanMS: 600_000,
+		PeakMinute: now.Truncate(time.Minute), PeakMinuteBusyMS: 90_000,
+	}}
+
 	tests := []struct {
 		name           string
 		tenantID       string
@@ -85,6 +91,7 @@ func TestThreadsHandler_ServeHTTP(t *testing.T) {
 				baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
 				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
 				redis.On("Get", mock.Anything, baseKey+":threads").Return(string(cachedJSON), nil)
+				ch.On("GetQueueLoad", mock.Anything, tenantID.String(), jobID.String()).Return(queueLoads, nil)
 			},
 			expectedStatus: http.StatusOK,
 			checkBody: func(t *testing.T, body []byte) {
@@ -92,6 +99,63 @@ func TestThreadsHandler_ServeHTTP(t *testing.T) {
 				require.NoError(t, json.Unmarshal(body, &resp))
 				assert.Equal(t, 2, resp.TotalThreads)
 				assert.Len(t, resp.Threads, 2)
+
+				// Without configured thread counts the observed ones stand in.
+				require.NotNil(t, resp.Capacity)
+				assert.True(t, resp.Capacity.Estimated)
+				require.Len(t, resp.Capacity.Queues, 1)
+				assert.Equal(t, 2, resp.Capacity.Queues[0].ConfiguredThreads)
+				assert.Equal(t, 75.0, resp.Capacity.Queues[0].PeakMinuteBusyPct)
+			},
+		},
+		{
+			name:     "capacity_uses_configured_thread_counts",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				job := *completeJob
+				job.ThreadCounts = map[string]int{"Fast": 4, "List": 20}
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(&job, nil)
+				baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":threads").Return(`{"api_threads":[],"sql_threads":[],"source":"jar_parsed"}`, nil)
+				ch.On("GetQueueLoad", mock.Anything, tenantID.String(), jobID.String()).Return(queueLoads, nil)
+			},
+			expectedStatus: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var resp domain.JARThreadStatsResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				require.NotNil(t, resp.Capacity)
+				assert.False(t, resp.Capacity.Estimated)
+				require.Len(t, resp.Capacity.Queues, 2)
+
+				fast := resp.Capacity.Queues[0]
+				assert.Equal(t, "Fast", fast.Queue)
+				assert.Equal(t, 4, fast.ConfiguredThreads)
+				assert.Equal(t, 2, fast.ObservedThreads)
+				assert.Equal(t, 37.5, fast.PeakMinuteBusyPct)
+				assert.Equal(t, domain.CapacityHealthy, fast.Verdict)
+
+				list := resp.Capacity.Queues[1]
+				assert.Equal(t, "List", list.Queue)
+				assert.Equal(t, 20, list.ConfiguredThreads)
+				assert.Zero(t, list.ObservedThreads)
+			},
+		},
+		{
+			name:     "queue_load_error_leaves_capacity_out",
+			tenantID: tenantID.String(),
+			jobIDStr: jobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, redis *testutil.MockRedisCache) {
+				pg.On("GetJob", mock.Anything, tenantID, jobID).Return(completeJob, nil)
+				baseKey := fmt.Sprintf("tenant:%s:dashboard:%s", tenantID.String(), jobID.String())
+				redis.On("TenantKey", tenantID.String(), "dashboard", jobID.String()).Return(baseKey)
+				redis.On("Get", mock.Anything, baseKey+":threads").Return(string(cachedJSON), nil)
+				ch.On("GetQueueLoad", mock.Anything, tenantID.String(), jobID.String()).Return(nil, errors.New("clickhouse unavailable"))
+			},
+			expectedStatus: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				assert.JSONEq(t, string(cachedJSON), string(body))
 			},
 		},
 		{
@@ -184,6 +248,7 @@ func TestThreadsHandler_ServeHTTP(t *testing.T) {
 			}
 
 			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
 			redis.AssertExpectations(t)
 		})
 	}
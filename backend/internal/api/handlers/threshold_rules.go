@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// maxThresholdRulesPerTenant caps the number of rules evaluated per job.
+const maxThresholdRulesPerTenant = 100
+
+// thresholdRuleRequest is the body of rule create and update requests.
+// Enabled defaults to true.
+type thresholdRuleRequest struct {
+	Name       string                     `json:"name"`
+	Scope      domain.ThresholdScope      `json:"scope"`
+	ScopeValue string                     `json:"scope_value"`
+	Metric     domain.ThresholdMetric     `json:"metric"`
+	Comparator domain.ThresholdComparator `json:"comparator"`
+	Value      float64                    `json:"value"`
+	Severity   domain.ThresholdSeverity   `json:"severity"`
+	Enabled    *bool                      `json:"enabled"`
+}
+
+// apply copies the request onto rule and validates the result.
+func (req thresholdRuleRequest) apply(rule *domain.ThresholdRule) error {
+	rule.Name = req.Name
+	rule.Scope = req.Scope
+	rule.ScopeValue = req.ScopeValue
+	rule.Metric = req.Metric
+	rule.Comparator = req.Comparator
+	rule.Value = req.Value
+	rule.Severity = req.Severity
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	return worker.ValidateThresholdRule(rule)
+}
+
+// ThresholdRuleHandlers provides HTTP handlers for tenant-defined threshold
+// rules and the violations recorded when jobs complete.
+type ThresholdRuleHandlers struct {
+	pg storage.PostgresStore
+}
+
+// NewThresholdRuleHandlers creates the threshold rule handlers.
+func NewThresholdRuleHandlers(pg storage.PostgresStore) *ThresholdRuleHandlers {
+	return &ThresholdRuleHandlers{pg: pg}
+}
+
+// tenantUUID extracts and parses the tenant ID, writing the error response
+// when it is missing or malformed.
+func (h *ThresholdRuleHandlers) tenantUUID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tenantID := middleware.GetTenantID(r.Context())
+	if tenantID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+		return uuid.Nil, false
+	}
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return tid, true
+}
+
+// ListRules handles GET /api/v1/threshold-rules.
+func (h *ThresholdRuleHandlers) ListRules() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantUUID(w, r)
+		if !ok {
+			return
+		}
+
+		rules, err := h.pg.ListThresholdRules(r.Context(), tid)
+		if err != nil {
+			slog.Error("failed to list threshold rules", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list threshold rules")
+			return
+		}
+		if rules == nil {
+			rules = []domain.ThresholdRule{}
+		}
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"rules": rules,
+		})
+	})
+}
+
+// CreateRule handles POST /api/v1/threshold-rules.
+func (h *ThresholdRuleHandlers) CreateRule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantUUID(w, r)
+		if !ok {
+			return
+		}
+
+		var req thresholdRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+		rule := &domain.ThresholdRule{TenantID: tid}
+		if err := req.apply(rule); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		existing, err := h.pg.ListThresholdRules(r.Context(), tid)
+		if err == nil && len(existing) >= maxThresholdRulesPerTenant {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "maximum threshold rules limit reached (100)")
+			return
+		}
+
+		if err := h.pg.CreateThresholdRule(r.Context(), rule); err != nil {
+			slog.Error("failed to create threshold rule", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create threshold rule")
+			return
+		}
+
+		api.JSON(w, http.StatusCreated, rule)
+	})
+}
+
+// UpdateRule handles PUT /api/v1/threshold-rules/{rule_id}.
+func (h *ThresholdRuleHandlers) UpdateRule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantUUID(w, r)
+		if !ok {
+			return
+		}
+
+		ruleID, ok := pathID(w, r, "rule_id")
+		if !ok {
+			return
+		}
+
+		var req thresholdRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+			return
+		}
+
+		rule, err := h.pg.GetThresholdRule(r.Context(), tid, ruleID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "threshold rule not found")
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve threshold rule")
+			}
+			return
+		}
+		if err := req.apply(rule); err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return
+		}
+
+		if err := h.pg.UpdateThresholdRule(r.Context(), rule); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "threshold rule not found")
+				return
+			}
+			slog.Error("failed to update threshold rule", "rule_id", ruleID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to update threshold rule")
+			return
+		}
+
+		api.JSON(w, http.StatusOK, rule)
+	})
+}
+
+// DeleteRule handles DELETE /api/v1/threshold-rules/{rule_id}.
+func (h *ThresholdRuleHandlers) DeleteRule() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := h.tenantUUID(w, r)
+		if !ok {
+			return
+		}
+
+		ruleID, ok := pathID(w, r, "rule_id")
+		if !ok {
+			return
+		}
+
+		if err := h.pg.DeleteThresholdRule(r.Context(), tid, ruleID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "threshold rule not found")
+				return
+			}
+			slog.Error("failed to delete threshold rule", "rule_id", ruleID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to delete threshold rule")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ListViolations handles GET /api/v1/analysis/{job_id}/violations.
+func (h *ThresholdRuleHandlers) ListViolations() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := middleware.GetTenantID(r.Context())
+		if tenantID == "" {
+			api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing tenant context")
+			return
+		}
+
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+
+		job, err := h.pg.GetJob(r.Context(), tid, jobID)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+		if job.Status != domain.JobStatusComplete {
+			api.Error(w, http.StatusConflict, api.ErrCodeInvalidRequest, "analysis is not yet complete")
+			return
+		}
+
+		violations, err := h.pg.ListJobViolations(r.Context(), tid, jobID)
+		if err != nil {
+			slog.Error("failed to list violations", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list violations")
+			return
+		}
+		if violations == nil {
+			violations = []domain.ThresholdViolation{}
+		}
+
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"job_id":     jobID,
+			"evaluated":  job.ViolationCount != nil,
+			"violations": violations,
+			"total":      len(violations),
+		})
+	})
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var fixedRuleID = uuid.MustParse("00000000-0000-0000-0000-000000000010")
+
+func TestThresholdRuleHandlers_CreateRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenantID   string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "creates rule with defaults",
+			tenantID: fixedTenantID.String(),
+			body:     `{"name":"Help Desk p95","scope":"form","scope_value":"HPD:Help Desk","metric":"p95","comparator":"gt","value":2000}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("ListThresholdRules", mock.Anything, fixedTenantID).Return([]domain.ThresholdRule{}, nil)
+				pg.On("CreateThresholdRule", mock.Anything, mock.MatchedBy(func(r *domain.ThresholdRule) bool {
+					return r.TenantID == fixedTenantID && r.Enabled &&
+						r.Severity == domain.ThresholdSeverityWarning && r.Value == 2000
+				})).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.ThresholdRule
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, domain.ThresholdScopeForm, resp.Scope)
+				assert.True(t, resp.Enabled)
+			},
+		},
+		{
+			name:     "honours enabled false",
+			tenantID: fixedTenantID.String(),
+			body:     `{"name":"errors","scope":"global","metric":"error_rate","comparator":"gte","value":5,"severity":"critical","enabled":false}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("ListThresholdRules", mock.Anything, fixedTenantID).Return([]domain.ThresholdRule{}, nil)
+				pg.On("CreateThresholdRule", mock.Anything, mock.MatchedBy(func(r *domain.ThresholdRule) bool {
+					return !r.Enabled && r.Severity == domain.ThresholdSeverityCritical
+				})).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "invalid metric for scope returns 400",
+			tenantID:   fixedTenantID.String(),
+			body:       `{"name":"gap","scope":"form","scope_value":"HPD:Help Desk","metric":"max_gap","comparator":"gt","value":60000}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, "cannot be evaluated over form scope")
+			},
+		},
+		{
+			name:       "invalid JSON returns 400",
+			tenantID:   fixedTenantID.String(),
+			body:       `{`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing tenant returns 401",
+			body:       `{}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "rule limit returns 409",
+			tenantID: fixedTenantID.String(),
+			body:     `{"name":"errors","scope":"global","metric":"error_rate","comparator":"gt","value":5}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("ListThresholdRules", mock.Anything, fixedTenantID).
+					Return(make([]domain.ThresholdRule, maxThresholdRulesPerTenant), nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:     "store error returns 500",
+			tenantID: fixedTenantID.String(),
+			body:     `{"name":"errors","scope":"global","metric":"error_rate","comparator":"gt","value":5}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("ListThresholdRules", mock.Anything, fixedTenantID).Return([]domain.ThresholdRule{}, nil)
+				pg.On("CreateThresholdRule", mock.Anything, mock.Anything).Return(errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/threshold-rules", bytes.NewBufferString(tc.body))
+			if tc.tenantID != "" {
+				req = injectAuth(req, tc.tenantID)
+			}
+
+			w := httptest.NewRecorder()
+			NewThresholdRuleHandlers(pg).CreateRule().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestThresholdRuleHandlers_ListRules(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListThresholdRules", mock.Anything, fixedTenantID).Return(nil, nil)
+
+	req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/threshold-rules", nil), fixedTenantID.String())
+	w := httptest.NewRecorder()
+	NewThresholdRuleHandlers(pg).ListRules().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rules":[]}`, w.Body.String())
+	pg.AssertExpectations(t)
+}
+
+func TestThresholdRuleHandlers_UpdateRule(t *testing.T) {
+	existing := func() *domain.ThresholdRule {
+		return &domain.ThresholdRule{
+			ID: fixedRuleID, TenantID: fixedTenantID, Name: "old",
+			Scope: domain.ThresholdScopeGlobal, Metric: domain.ThresholdMetricAvg,
+			Comparator: domain.ThresholdComparatorGT, Value: 100,
+			Severity: domain.ThresholdSeverityInfo, Enabled: true,
+		}
+	}
+	body := `{"name":"new","scope":"queue","scope_value":"Fast","metric":"max_gap","comparator":"gt","value":30000,"severity":"critical"}`
+
+	tests := []struct {
+		name       string
+		ruleID     string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+	}{
+		{
+			name:   "updates rule",
+			ruleID: fixedRuleID.String(),
+			body:   body,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetThresholdRule", mock.Anything, fixedTenantID, fixedRuleID).Return(existing(), nil)
+				pg.On("UpdateThresholdRule", mock.Anything, mock.MatchedBy(func(r *domain.ThresholdRule) bool {
+					return r.ID == fixedRuleID && r.Name == "new" && r.Scope == domain.ThresholdScopeQueue &&
+						r.Severity == domain.ThresholdSeverityCritical
+				})).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid rule_id returns 400",
+			ruleID:     "nope",
+			body:       body,
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown rule returns 404",
+			ruleID: fixedRuleID.String(),
+			body:   body,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetThresholdRule", mock.Anything, fixedTenantID, fixedRuleID).
+					Return(nil, fmt.Errorf("postgres: threshold rule not found: %s", fixedRuleID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "invalid update returns 400",
+			ruleID: fixedRuleID.String(),
+			body:   `{"name":"new","scope":"global","metric":"avg","comparator":"between","value":1}`,
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetThresholdRule", mock.Anything, fixedTenantID, fixedRuleID).Return(existing(), nil)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/threshold-rules/"+tc.ruleID, bytes.NewBufferString(tc.body))
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"rule_id": tc.ruleID})
+
+			w := httptest.NewRecorder()
+			NewThresholdRuleHandlers(pg).UpdateRule().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestThresholdRuleHandlers_DeleteRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"deletes rule", nil, http.StatusNoContent},
+		{"unknown rule returns 404", fmt.Errorf("postgres: threshold rule not found: %s", fixedRuleID), http.StatusNotFound},
+		{"store error returns 500", errors.New("db down"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			pg.On("DeleteThresholdRule", mock.Anything, fixedTenantID, fixedRuleID).Return(tc.err)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/threshold-rules/"+fixedRuleID.String(), nil)
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"rule_id": fixedRuleID.String()})
+
+			w := httptest.NewRecorder()
+			NewThresholdRuleHandlers(pg).DeleteRule().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestThresholdRuleHandlers_ListViolations(t *testing.T) {
+	count := 1
+	evaluatedJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete, ViolationCount: &count}
+	parsingJob := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusParsing}
+
+	tests := []struct {
+		name       string
+		jobID      string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "returns recorded violations",
+			jobID: fixedJobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(evaluatedJob, nil)
+				pg.On("ListJobViolations", mock.Anything, fixedTenantID, fixedJobID).Return([]domain.ThresholdViolation{{
+					RuleID: fixedRuleID, RuleName: "errors", Metric: domain.ThresholdMetricErrorRate,
+					Comparator: domain.ThresholdComparatorGT, Threshold: 5, Observed: 9.5,
+					Severity: domain.ThresholdSeverityCritical,
+				}}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp struct {
+					Evaluated  bool                        `json:"evaluated"`
+					Violations []domain.ThresholdViolation `json:"violations"`
+					Total      int                         `json:"total"`
+				}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.True(t, resp.Evaluated)
+				assert.Equal(t, 1, resp.Total)
+				assert.Equal(t, 9.5, resp.Violations[0].Observed)
+			},
+		},
+		{
+			name:       "invalid job_id returns 400",
+			jobID:      "bad",
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "job not found returns 404",
+			jobID: fixedJobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(nil, fmt.Errorf("postgres: job not found: %s", fixedJobID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "incomplete job returns 409",
+			jobID: fixedJobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(parsingJob, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+tc.jobID+"/violations", nil)
+			req = injectAuth(req, fixedTenantID.String())
+			req = mux.SetURLVars(req, map[string]string{"job_id": tc.jobID})
+
+			w := httptest.NewRecorder()
+			NewThresholdRuleHandlers(pg).ListViolations().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// jobTimeRanger reads the time range of the entries of a job, against which
+// relative time bounds are resolved. storage.ClickHouseStore implements it.
+type jobTimeRanger interface {
+	GetJobTimeRange(ctx context.Context, tenantID, jobID string) (*storage.JobTimeRange, error)
+}
+
+// ResolvedTimeRange echoes the time bounds of a request: the expressions
+// given and the absolute times they resolved to, so that clients can show
+// what was queried.
+type ResolvedTimeRange struct {
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	FromExpr string     `json:"from_expr,omitempty"`
+	ToExpr   string     `json:"to_expr,omitempty"`
+}
+
+// timeBounds are the optional lower and upper time bounds of a request,
+// each an RFC3339 timestamp or an expression relative to the capture such
+// as capture_end-15m (see search.TimeExpr).
+type timeBounds struct {
+	from, to *search.TimeExpr
+}
+
+// parseTimeBounds parses the query parameters fromParam and toParam,
+// writing a 400 response naming the parameter when one is malformed.
+func parseTimeBounds(w http.ResponseWriter, params url.Values, fromParam, toParam string) (timeBounds, bool) {
+	var b timeBounds
+	for _, p := range []struct {
+		name string
+		dst  **search.TimeExpr
+	}{{fromParam, &b.from}, {toParam, &b.to}} {
+		v := params.Get(p.name)
+		if v == "" {
+			continue
+		}
+		e, err := search.ParseTimeExpr(v)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid "+p.name+": "+err.Error())
+			return timeBounds{}, false
+		}
+		*p.dst = e
+	}
+	return b, true
+}
+
+// empty reports whether neither bound was given.
+func (b timeBounds) empty() bool {
+	return b.from == nil && b.to == nil
+}
+
+// relative reports whether a bound needs the capture's time range.
+func (b timeBounds) relative() bool {
+	return (b.from != nil && b.from.Relative()) || (b.to != nil && b.to.Relative())
+}
+
+// resolve returns the absolute bounds within a capture's time range, which
+// may be nil when no bound is relative.
+func (b timeBounds) resolve(rng *storage.JobTimeRange) (from, to *time.Time) {
+	var start, end time.Time
+	if rng != nil {
+		start, end = rng.Start, rng.End
+	}
+	if b.from != nil {
+		t := b.from.Resolve(start, end)
+		from = &t
+	}
+	if b.to != nil {
+		t := b.to.Resolve(start, end)
+		to = &t
+	}
+	return from, to
+}
+
+// echo returns the time range to report in a response, or nil when the
+// request gave no bound.
+func (b timeBounds) echo(from, to *time.Time) *ResolvedTimeRange {
+	if b.empty() {
+		return nil
+	}
+	tr := &ResolvedTimeRange{From: from, To: to}
+	if b.from != nil {
+		tr.FromExpr = b.from.String()
+	}
+	if b.to != nil {
+		tr.ToExpr = b.to.String()
+	}
+	return tr
+}
+
+// resolveTimeBounds resolves b for a job, reading the capture's time range
+// only when a bound is relative to it. It writes the error response and
+// returns false when the range cannot be read.
+func resolveTimeBounds(w http.ResponseWriter, r *http.Request, ch jobTimeRanger, tenantID, jobID string, b timeBounds) (from, to *time.Time, ok bool) {
+	if !b.relative() {
+		from, to = b.resolve(nil)
+		return from, to, true
+	}
+	rng, err := ch.GetJobTimeRange(r.Context(), tenantID, jobID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoJobEntries) {
+			api.Error(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest, "the analysis has no entries to resolve capture time expressions against")
+		} else {
+			slog.Error("failed to get job time range for time bounds", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to determine analysis time range")
+		}
+		return nil, nil, false
+	}
+	from, to = b.resolve(rng)
+	return from, to, true
+}
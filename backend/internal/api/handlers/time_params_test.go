@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// TestTimeBounds_SearchAndThreadTimeline runs the same time bounds through
+// the search and the thread timeline, which must resolve and reject them
+// alike.
+func TestTimeBounds_SearchAndThreadTimeline(t *testing.T) {
+	start := time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+	rng := &storage.JobTimeRange{Start: start, End: end}
+	job := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+
+	tests := []struct {
+		name               string
+		from, to           string
+		wantFrom, wantTo   time.Time
+		needsRange         bool
+		wantStatus         int
+		wantErrorSubstring string
+	}{
+		{
+			name: "last 15 minutes", from: "capture_end-15m", to: "capture_end",
+			wantFrom: end.Add(-15 * time.Minute), wantTo: end, needsRange: true, wantStatus: http.StatusOK,
+		},
+		{
+			name: "first hour", from: "capture_start", to: "Capture_Start + 1h",
+			wantFrom: start, wantTo: start.Add(time.Hour), needsRange: true, wantStatus: http.StatusOK,
+		},
+		{
+			name: "percentages", from: "capture_25%", to: "capture_50%",
+			wantFrom: start.Add(time.Hour), wantTo: start.Add(2 * time.Hour), needsRange: true, wantStatus: http.StatusOK,
+		},
+		{
+			name: "offset beyond the capture is clamped", from: "capture_start-2h", to: "capture_start+30m",
+			wantFrom: start, wantTo: start.Add(30 * time.Minute), needsRange: true, wantStatus: http.StatusOK,
+		},
+		{
+			name: "absolute and relative", from: "2026-02-10T10:00:00Z", to: "capture_end-1h",
+			wantFrom: start.Add(time.Hour), wantTo: end.Add(-time.Hour), needsRange: true, wantStatus: http.StatusOK,
+		},
+		{
+			name: "malformed expression", from: "capture_end-15x",
+			wantStatus: http.StatusBadRequest, wantErrorSubstring: `invalid %s: "capture_end-15x": invalid offset duration`,
+		},
+		{
+			name: "unknown anchor", from: "capture_middle",
+			wantStatus: http.StatusBadRequest, wantErrorSubstring: `invalid %s: "capture_middle": unknown capture anchor`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("search/"+tt.name, func(t *testing.T) {
+			h, ch, _ := setupSearchLogsHandler()
+			if tt.needsRange {
+				ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(rng, nil).Once()
+			}
+			var got storage.SearchQuery
+			ch.On("SearchEntries", mock.Anything, fixedTenantID.String(), fixedJobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+				Run(func(args mock.Arguments) { got = args.Get(3).(storage.SearchQuery) }).
+				Return(&storage.SearchResult{}, nil).Maybe()
+			setupCHFacets(ch, fixedTenantID.String(), fixedJobID.String())
+
+			params := url.Values{"time_from": {tt.from}}
+			if tt.to != "" {
+				params.Set("time_to", tt.to)
+			}
+			path := "/api/v1/analysis/" + fixedJobID.String() + "/search?" + params.Encode()
+			rr := newTestRequest(http.MethodGet, path).tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).serve(h)
+
+			require.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+			if tt.wantErrorSubstring != "" {
+				assert.Contains(t, decodeError(t, rr).Message, fmt.Sprintf(tt.wantErrorSubstring, "time_from"))
+				return
+			}
+			require.NotNil(t, got.TimeFrom)
+			require.NotNil(t, got.TimeTo)
+			assert.True(t, tt.wantFrom.Equal(*got.TimeFrom), "time_from resolved to %s", got.TimeFrom)
+			assert.True(t, tt.wantTo.Equal(*got.TimeTo), "time_to resolved to %s", got.TimeTo)
+
+			var resp SearchResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.NotNil(t, resp.TimeRange)
+			assert.Equal(t, tt.from, resp.TimeRange.FromExpr)
+			assert.True(t, tt.wantFrom.Equal(*resp.TimeRange.From))
+			assert.True(t, tt.wantTo.Equal(*resp.TimeRange.To))
+			ch.AssertExpectations(t)
+		})
+
+		t.Run("thread timeline/"+tt.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			var got domain.ThreadTimelineQuery
+			if tt.wantStatus == http.StatusOK {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+				ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).Return(rng, nil)
+				ch.On("GetThreadTimeline", mock.Anything, fixedTenantID.String(), fixedJobID.String(), mock.Anything).
+					Run(func(args mock.Arguments) { got = args.Get(3).(domain.ThreadTimelineQuery) }).
+					Return([]domain.ThreadTimeline{}, nil)
+			}
+
+			params := url.Values{"from": {tt.from}}
+			if tt.to != "" {
+				params.Set("to", tt.to)
+			}
+			path := "/api/v1/analyses/" + fixedJobID.String() + "/threads/timeline?" + params.Encode()
+			rr := newTestRequest(http.MethodGet, path).tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).
+				serve(NewThreadTimelineHandler(pg, ch))
+
+			require.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+			if tt.wantErrorSubstring != "" {
+				assert.Contains(t, decodeError(t, rr).Message, fmt.Sprintf(tt.wantErrorSubstring, "from"))
+				return
+			}
+			assert.True(t, tt.wantFrom.Equal(got.From), "from resolved to %s", got.From)
+			assert.True(t, tt.wantTo.Equal(got.To), "to resolved to %s", got.To)
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTimeBounds_NoEntries(t *testing.T) {
+	h, ch, _ := setupSearchLogsHandler()
+	ch.On("GetJobTimeRange", mock.Anything, fixedTenantID.String(), fixedJobID.String()).
+		Return(nil, fmt.Errorf("clickhouse: job %s: %w", fixedJobID, storage.ErrNoJobEntries))
+
+	path := "/api/v1/analysis/" + fixedJobID.String() + "/search?time_from=capture_end-15m"
+	rr := newTestRequest(http.MethodGet, path).tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).serve(h)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	ch.AssertNotCalled(t, "SearchEntries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTimeBounds_AbsoluteSkipsRange(t *testing.T) {
+	h, ch, _ := setupSearchLogsHandler()
+	ch.On("SearchEntries", mock.Anything, fixedTenantID.String(), fixedJobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(&storage.SearchResult{}, nil)
+	setupCHFacets(ch, fixedTenantID.String(), fixedJobID.String())
+
+	path := "/api/v1/analysis/" + fixedJobID.String() + "/search?time_from=2026-02-10T09:00:00Z"
+	rr := newTestRequest(http.MethodGet, path).tenant(fixedTenantID.String()).vars("job_id", fixedJobID.String()).serve(h)
+	require.Equal(t, http.StatusOK, rr.Code)
+	ch.AssertNotCalled(t, "GetJobTimeRange", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"bufio"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
@@ -19,13 +22,31 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/trace"
 )
 
+// traceFlushEvery is the number of entries written between flushes of a
+// streamed trace response.
+const traceFlushEvery = 1000
+
+// traceWriteTimeout is how long each flush of a streamed trace response
+// may take. The deadline is pushed back on every flush so that large
+// traces are not cut off by the server's WriteTimeout.
+const traceWriteTimeout = 60 * time.Second
+
+const ndjsonContentType = "application/x-ndjson"
+
+// errTracePageFull stops the trace stream once a page is complete.
+var errTracePageFull = errors.New("trace page full")
+
 type TraceHandler struct {
-	ch    storage.ClickHouseStore
-	cache storage.RedisCache
+	ch         storage.ClickHouseStore
+	cache      storage.RedisCache
+	maxEntries int
 }
 
-func NewTraceHandler(ch storage.ClickHouseStore, cache storage.RedisCache) *TraceHandler {
-	return &TraceHandler{ch: ch, cache: cache}
+// NewTraceHandler creates a TraceHandler. maxEntries caps the entries of a
+// single response; the rest of a larger trace is fetched with the
+// next_cursor of the response. maxEntries <= 0 disables the cap.
+func NewTraceHandler(ch storage.ClickHouseStore, cache storage.RedisCache, maxEntries int) *TraceHandler {
+	return &TraceHandler{ch: ch, cache: cache, maxEntries: maxEntries}
 }
 
 func (h *TraceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -35,11 +56,11 @@ func (h *TraceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	if _, err := uuid.Parse(jobIDStr); err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
+	jobIDStr := jobID.String()
 
 	traceID := mux.Vars(r)["trace_id"]
 	if traceID == "" {
@@ -47,37 +68,212 @@ func (h *TraceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := h.ch.GetTraceEntries(r.Context(), tenantID, jobIDStr, traceID)
-	if err != nil {
+	params := r.URL.Query()
+	limit := h.maxEntries
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "limit must be a positive integer")
+			return
+		}
+		if limit <= 0 || n < limit {
+			limit = n
+		}
+	}
+
+	var q storage.TraceQuery
+	if v := params.Get("cursor"); v != "" {
+		after, err := decodeTraceCursor(v)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid cursor")
+			return
+		}
+		q.After = after
+	}
+	if limit > 0 {
+		// One entry more than the page tells whether the trace goes on.
+		q.Limit = limit + 1
+	}
+
+	ndjson := params.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+	sw := newTraceStreamWriter(w, traceID, ndjson)
+	err := h.ch.GetTraceEntriesStream(r.Context(), tenantID, jobIDStr, traceID, q, func(e domain.LogEntry) error {
+		if limit > 0 && sw.count == limit {
+			return errTracePageFull
+		}
+		return sw.write(e)
+	})
+	if errors.Is(err, errTracePageFull) {
+		sw.truncated = true
+		err = nil
+	}
+	if err != nil && !sw.started {
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "trace search failed")
 		return
 	}
+	if err != nil {
+		slog.Error("trace stream failed", "trace_id", traceID, "entries_written", sw.count, "error", err)
+	}
+	sw.finish(err)
+}
 
-	results := make([]map[string]interface{}, 0, len(entries))
-	var totalDuration int
-	for _, e := range entries {
-		results = append(results, map[string]interface{}{
-			"id":     e.EntryID,
-			"fields": entryToFieldMap(e),
-		})
-		totalDuration += int(e.DurationMS)
+// traceEntry is an entry of the trace response.
+type traceEntry struct {
+	ID     string                 `json:"id"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// traceTrailer ends a trace response. Truncated is set when the trace has
+// more entries than were returned; they follow NextCursor.
+type traceTrailer struct {
+	EntryCount    int    `json:"entry_count"`
+	TotalDuration int    `json:"total_duration"`
+	Truncated     bool   `json:"truncated"`
+	NextCursor    string `json:"next_cursor,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// traceStreamWriter writes trace entries to the response as they are read,
+// either as a JSON object whose entries array is written incrementally or
+// as NDJSON with one entry per line and a final trailer line. Nothing is
+// written before the first entry, so that an early error can still be
+// answered with an error status.
+type traceStreamWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	buf     *bufio.Writer
+	enc     *json.Encoder
+	traceID string
+	ndjson  bool
+
+	started       bool
+	count         int
+	totalDuration int
+	truncated     bool
+	last          storage.TraceCursor
+	err           error
+}
+
+func newTraceStreamWriter(w http.ResponseWriter, traceID string, ndjson bool) *traceStreamWriter {
+	buf := bufio.NewWriterSize(w, 32<<10)
+	return &traceStreamWriter{
+		w:       w,
+		rc:      http.NewResponseController(w),
+		buf:     buf,
+		enc:     json.NewEncoder(buf),
+		traceID: traceID,
+		ndjson:  ndjson,
 	}
+}
 
-	api.JSON(w, http.StatusOK, map[string]interface{}{
-		"trace_id":       traceID,
-		"entries":        results,
-		"entry_count":    len(results),
-		"total_duration": totalDuration,
-	})
+func (sw *traceStreamWriter) start() {
+	sw.started = true
+	if sw.ndjson {
+		sw.w.Header().Set("Content-Type", ndjsonContentType)
+	} else {
+		sw.w.Header().Set("Content-Type", "application/json")
+	}
+	sw.w.Header().Set("X-Accel-Buffering", "no")
+	_ = sw.rc.SetWriteDeadline(time.Now().Add(traceWriteTimeout))
+	sw.w.WriteHeader(http.StatusOK)
+	if !sw.ndjson {
+		traceID, _ := json.Marshal(sw.traceID)
+		fmt.Fprintf(sw.buf, `{"trace_id":%s,"entries":[`, traceID)
+	}
+}
+
+// write appends an entry to the response. The error of a failed write,
+// typically a client that went away, stops the stream.
+func (sw *traceStreamWriter) write(e domain.LogEntry) error {
+	if !sw.started {
+		sw.start()
+	}
+	if sw.count > 0 && !sw.ndjson {
+		sw.buf.WriteByte(',')
+	}
+	if err := sw.enc.Encode(traceEntry{ID: e.EntryID, Fields: entryToFieldMap(e)}); err != nil {
+		return err
+	}
+	sw.count++
+	sw.totalDuration += int(e.DurationMS)
+	sw.last = storage.TraceCursor{Timestamp: e.Timestamp.Time, EntryID: e.EntryID}
+	if sw.count%traceFlushEvery == 0 {
+		return sw.flush()
+	}
+	return nil
+}
+
+func (sw *traceStreamWriter) flush() error {
+	if err := sw.buf.Flush(); err != nil {
+		return err
+	}
+	_ = sw.rc.SetWriteDeadline(time.Now().Add(traceWriteTimeout))
+	if err := sw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish writes the trailer. streamErr is reported in it when the stream
+// failed after entries were written.
+func (sw *traceStreamWriter) finish(streamErr error) {
+	if !sw.started {
+		sw.start()
+	}
+	trailer := traceTrailer{
+		EntryCount:    sw.count,
+		TotalDuration: sw.totalDuration,
+		Truncated:     sw.truncated,
+	}
+	if sw.truncated {
+		trailer.NextCursor = encodeTraceCursor(sw.last)
+	}
+	if streamErr != nil {
+		trailer.Error = "trace stream interrupted"
+	}
+
+	if sw.ndjson {
+		_ = sw.enc.Encode(map[string]interface{}{"trace_id": sw.traceID, "trailer": trailer})
+	} else {
+		// The trailer fields close the object opened in start.
+		b, _ := json.Marshal(trailer)
+		sw.buf.WriteString("],")
+		sw.buf.Write(b[1:])
+	}
+	if err := sw.flush(); err != nil {
+		slog.Debug("trace stream trailer write failed", "trace_id", sw.traceID, "error", err)
+	}
+}
+
+// encodeTraceCursor returns the opaque cursor of the trace entries after c.
+func encodeTraceCursor(c storage.TraceCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.EntryID))
+}
+
+func decodeTraceCursor(s string) (*storage.TraceCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	ts, entryID, ok := strings.Cut(string(raw), "|")
+	if !ok || entryID == "" {
+		return nil, errors.New("malformed trace cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.TraceCursor{Timestamp: t, EntryID: entryID}, nil
 }
 
 type WaterfallHandler struct {
 	ch    storage.ClickHouseStore
 	cache storage.RedisCache
+	pg    storage.PostgresStore
 }
 
-func NewWaterfallHandler(ch storage.ClickHouseStore, cache storage.RedisCache) *WaterfallHandler {
-	return &WaterfallHandler{ch: ch, cache: cache}
+func NewWaterfallHandler(ch storage.ClickHouseStore, cache storage.RedisCache, pg storage.PostgresStore) *WaterfallHandler {
+	return &WaterfallHandler{ch: ch, cache: cache, pg: pg}
 }
 
 func (h *WaterfallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -88,11 +284,11 @@ func (h *WaterfallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	if _, err := uuid.Parse(jobIDStr); err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
+	jobIDStr := jobID.String()
 
 	traceID := mux.Vars(r)["trace_id"]
 	if traceID == "" {
@@ -135,6 +331,7 @@ func (h *WaterfallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	spans := trace.BuildHierarchy(entries)
+	nameSpanAPIs(spans, newLazyAPILegend(r.Context(), h.pg, tenantID, jobIDStr))
 	flatSpans := trace.FlattenSpans(spans)
 	typeBreakdown := trace.ComputeTypeBreakdown(spans)
 	errorCount := trace.CountErrors(spans)
@@ -149,20 +346,20 @@ func (h *WaterfallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		flatSpans = trace.FlattenSpans(spans)
 	}
 
-	var traceStart, traceEnd time.Time
+	var traceStart, traceEnd domain.Timestamp
 	var totalDurationMS int64
 	if len(entries) > 0 {
 		traceStart = entries[0].Timestamp
 		traceEnd = entries[0].Timestamp
 		for _, e := range entries {
-			if e.Timestamp.Before(traceStart) {
+			if e.Timestamp.Before(traceStart.Time) {
 				traceStart = e.Timestamp
 			}
-			if e.Timestamp.After(traceEnd) {
+			if e.Timestamp.After(traceEnd.Time) {
 				traceEnd = e.Timestamp
 			}
 		}
-		totalDurationMS = traceEnd.Sub(traceStart).Milliseconds()
+		totalDurationMS = traceEnd.Sub(traceStart.Time).Milliseconds()
 	}
 
 	resp := domain.WaterfallResponse{
@@ -174,8 +371,8 @@ func (h *WaterfallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		PrimaryUser:     primaryUser,
 		PrimaryQueue:    primaryQueue,
 		TypeBreakdown:   typeBreakdown,
-		TraceStart:      traceStart.Format(time.RFC3339),
-		TraceEnd:        traceEnd.Format(time.RFC3339),
+		TraceStart:      traceStart,
+		TraceEnd:        traceEnd,
 		Spans:           spans,
 		FlatSpans:       flatSpans,
 		CriticalPath:    criticalPath,
@@ -204,11 +401,11 @@ func (h *TransactionSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	if _, err := uuid.Parse(jobIDStr); err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
+	jobIDStr := jobID.String()
 
 	params := domain.TransactionSearchParams{
 		User:     r.URL.Query().Get("user"),
@@ -301,11 +498,11 @@ func (h *ExportTraceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobIDStr := mux.Vars(r)["job_id"]
-	if _, err := uuid.Parse(jobIDStr); err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid job_id format")
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
 		return
 	}
+	jobIDStr := jobID.String()
 
 	traceID := mux.Vars(r)["trace_id"]
 	if traceID == "" {
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/trace"
+)
+
+// maxTraceDiffEntries caps the entries of each trace that are aligned. The
+// alignment takes time and memory in the product of the two lengths.
+const maxTraceDiffEntries = 2000
+
+// traceDiffRequest is the body of POST /api/v1/analyses/{job_id}/traces/diff.
+// The baseline trace is in the job of the path; the candidate trace is in
+// CandidateJobID, by default the same job.
+type traceDiffRequest struct {
+	BaselineTraceID  string `json:"baseline_trace_id"`
+	CandidateTraceID string `json:"candidate_trace_id"`
+	CandidateJobID   string `json:"candidate_job_id"`
+}
+
+// TraceDiffHandler serves POST /api/v1/analyses/{job_id}/traces/diff,
+// comparing two traces of the same operation entry by entry: what the slow
+// one did that the fast one did not.
+type TraceDiffHandler struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+func NewTraceDiffHandler(pg storage.PostgresStore, ch storage.ClickHouseStore) *TraceDiffHandler {
+	return &TraceDiffHandler{pg: pg, ch: ch}
+}
+
+func (h *TraceDiffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+	baselineJobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	var req traceDiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return
+	}
+	if req.BaselineTraceID == "" || req.CandidateTraceID == "" {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "baseline_trace_id and candidate_trace_id are required")
+		return
+	}
+	candidateJobID := baselineJobID
+	if req.CandidateJobID != "" {
+		if candidateJobID, ok = bodyID(w, "candidate_job_id", req.CandidateJobID); !ok {
+			return
+		}
+	}
+	if candidateJobID == baselineJobID && req.CandidateTraceID == req.BaselineTraceID {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "baseline and candidate must be different traces")
+		return
+	}
+
+	jobIDs := []uuid.UUID{baselineJobID}
+	if candidateJobID != baselineJobID {
+		jobIDs = append(jobIDs, candidateJobID)
+	}
+	for _, id := range jobIDs {
+		if _, err := h.pg.GetJob(r.Context(), tid, id); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found: "+id.String())
+			} else {
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+	}
+
+	var traces [2][]domain.LogEntry
+	truncated := false
+	for i, side := range []struct {
+		jobID   uuid.UUID
+		traceID string
+	}{{baselineJobID, req.BaselineTraceID}, {candidateJobID, req.CandidateTraceID}} {
+		entries, err := h.ch.GetTraceEntries(r.Context(), tid.String(), side.jobID.String(), side.traceID)
+		if err != nil {
+			slog.Error("failed to get trace entries for diff",
+				"job_id", side.jobID, "trace_id", side.traceID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "trace search failed")
+			return
+		}
+		if len(entries) == 0 {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "trace not found: "+side.traceID)
+			return
+		}
+		if len(entries) > maxTraceDiffEntries {
+			entries = entries[:maxTraceDiffEntries]
+			truncated = true
+		}
+		traces[i] = entries
+	}
+
+	diff := trace.DiffTraces(traces[0], traces[1])
+	diff.BaselineJobID = baselineJobID.String()
+	diff.BaselineTraceID = req.BaselineTraceID
+	diff.CandidateJobID = candidateJobID.String()
+	diff.CandidateTraceID = req.CandidateTraceID
+	diff.Truncated = truncated
+	api.JSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func diffTraceEntries(traceID string, sqlMS ...uint32) []domain.LogEntry {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	entries := []domain.LogEntry{{
+		EntryID: traceID + "-api", TraceID: traceID, LogType: domain.LogTypeAPI,
+		APICode: "SE", Form: "SRM:Request", DurationMS: 300, Timestamp: domain.NewTimestamp(base), Success: true,
+	}}
+	for i, ms := range sqlMS {
+		entries = append(entries, domain.LogEntry{
+			EntryID: traceID + "-sql", TraceID: traceID, LogType: domain.LogTypeSQL, SQLTable: "T100",
+			DurationMS: ms, Timestamp: domain.NewTimestamp(base.Add(time.Duration(i+1) * time.Millisecond)), Success: true,
+		})
+	}
+	return entries
+}
+
+func TestTraceDiffHandler(t *testing.T) {
+	m := newHandlerMocks()
+	m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(&domain.AnalysisJob{ID: fixedBaselineJobID}, nil)
+	m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(&domain.AnalysisJob{ID: fixedJobID}, nil)
+	m.ch.On("GetTraceEntries", mock.Anything, fixedTenantID.String(), fixedBaselineJobID.String(), "fast").
+		Return(diffTraceEntries("fast", 5), nil)
+	m.ch.On("GetTraceEntries", mock.Anything, fixedTenantID.String(), fixedJobID.String(), "slow").
+		Return(diffTraceEntries("slow", 5, 8000), nil)
+
+	w := newTestRequest(http.MethodPost, "/api/v1/analyses/"+fixedBaselineJobID.String()+"/traces/diff").
+		tenant(fixedTenantID.String()).
+		vars("job_id", fixedBaselineJobID.String()).
+		jsonBody(t, map[string]string{
+			"baseline_trace_id":  "fast",
+			"candidate_trace_id": "slow",
+			"candidate_job_id":   fixedJobID.String(),
+		}).
+		serve(NewTraceDiffHandler(m.pg, m.ch))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	m.assertExpectations(t)
+	var got domain.TraceDiff
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, fixedJobID.String(), got.CandidateJobID)
+	assert.Equal(t, "fast", got.BaselineTraceID)
+	assert.Equal(t, 2, got.Matched)
+	assert.Equal(t, 1, got.CandidateOnly)
+	assert.Equal(t, int64(8000), got.Delta.SQLTimeMS)
+	assert.False(t, got.Truncated)
+}
+
+func TestTraceDiffHandler_Errors(t *testing.T) {
+	otherJobID := fixedJobID.String()
+	tests := []struct {
+		name       string
+		body       map[string]string
+		setup      func(m *handlerMocks)
+		wantStatus int
+	}{
+		{
+			name:       "missing trace",
+			body:       map[string]string{"baseline_trace_id": "fast"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "same trace",
+			body:       map[string]string{"baseline_trace_id": "fast", "candidate_trace_id": "fast"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid candidate job",
+			body:       map[string]string{"baseline_trace_id": "fast", "candidate_trace_id": "slow", "candidate_job_id": "nope"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "candidate job of another tenant",
+			body: map[string]string{"baseline_trace_id": "fast", "candidate_trace_id": "slow", "candidate_job_id": otherJobID},
+			setup: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(&domain.AnalysisJob{}, nil)
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, pgx.ErrNoRows)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "unknown trace",
+			body: map[string]string{"baseline_trace_id": "fast", "candidate_trace_id": "slow"},
+			setup: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(&domain.AnalysisJob{}, nil)
+				m.ch.On("GetTraceEntries", mock.Anything, fixedTenantID.String(), fixedBaselineJobID.String(), "fast").
+					Return(diffTraceEntries("fast"), nil)
+				m.ch.On("GetTraceEntries", mock.Anything, fixedTenantID.String(), fixedBaselineJobID.String(), "slow").
+					Return([]domain.LogEntry{}, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "clickhouse error",
+			body: map[string]string{"baseline_trace_id": "fast", "candidate_trace_id": "slow"},
+			setup: func(m *handlerMocks) {
+				m.pg.On("GetJob", mock.Anything, fixedTenantID, fixedBaselineJobID).Return(&domain.AnalysisJob{}, nil)
+				m.ch.On("GetTraceEntries", mock.Anything, fixedTenantID.String(), fixedBaselineJobID.String(), "fast").
+					Return(nil, errors.New("timeout"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newHandlerMocks()
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+
+			w := newTestRequest(http.MethodPost, "/api/v1/analyses/"+fixedBaselineJobID.String()+"/traces/diff").
+				tenant(fixedTenantID.String()).
+				vars("job_id", fixedBaselineJobID.String()).
+				jsonBody(t, tt.body).
+				serve(NewTraceDiffHandler(m.pg, m.ch))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			m.assertExpectations(t)
+			if tt.setup == nil {
+				m.ch.AssertNotCalled(t, "GetTraceEntries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -16,6 +23,7 @@ import (
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
 )
 
@@ -24,7 +32,7 @@ import (
 // ---------------------------------------------------------------------------
 
 func TestTraceHandler_MissingTenantContext(t *testing.T) {
-	h := NewTraceHandler(nil, nil)
+	h := NewTraceHandler(nil, nil, 0)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/job-1/trace/T001", nil)
 	w := httptest.NewRecorder()
@@ -39,7 +47,7 @@ func TestTraceHandler_MissingTenantContext(t *testing.T) {
 }
 
 func TestTraceHandler_InvalidJobID(t *testing.T) {
-	h := NewTraceHandler(nil, nil)
+	h := NewTraceHandler(nil, nil, 0)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/not-a-uuid/trace/T001", nil)
 	ctx := middleware.WithTenantID(req.Context(), "test-tenant")
@@ -59,7 +67,7 @@ func TestTraceHandler_InvalidJobID(t *testing.T) {
 }
 
 func TestTraceHandler_EmptyTraceID(t *testing.T) {
-	h := NewTraceHandler(nil, nil)
+	h := NewTraceHandler(nil, nil, 0)
 
 	jobID := uuid.New()
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+jobID.String()+"/trace/", nil)
@@ -82,13 +90,13 @@ func TestTraceHandler_EmptyTraceID(t *testing.T) {
 func TestTraceHandler_SearchError(t *testing.T) {
 	mockCH := new(testutil.MockClickHouseStore)
 	mockRedis := new(testutil.MockRedisCache)
-	h := NewTraceHandler(mockCH, mockRedis)
+	h := NewTraceHandler(mockCH, mockRedis, 0)
 
 	jobID := uuid.New()
 	traceID := "T001"
 	tenantID := "test-tenant"
 
-	mockCH.On("GetTraceEntries", mock.Anything, tenantID, jobID.String(), traceID).
+	mockCH.On("GetTraceEntriesStream", mock.Anything, tenantID, jobID.String(), traceID, storage.TraceQuery{}).
 		Return(nil, errors.New("clickhouse error"))
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+jobID.String()+"/trace/"+traceID, nil)
@@ -113,13 +121,13 @@ func TestTraceHandler_SearchError(t *testing.T) {
 func TestTraceHandler_EmptyResults(t *testing.T) {
 	mockCH := new(testutil.MockClickHouseStore)
 	mockRedis := new(testutil.MockRedisCache)
-	h := NewTraceHandler(mockCH, mockRedis)
+	h := NewTraceHandler(mockCH, mockRedis, 0)
 
 	jobID := uuid.New()
 	traceID := "T-nonexistent"
 	tenantID := "test-tenant"
 
-	mockCH.On("GetTraceEntries", mock.Anything, tenantID, jobID.String(), traceID).
+	mockCH.On("GetTraceEntriesStream", mock.Anything, tenantID, jobID.String(), traceID, storage.TraceQuery{}).
 		Return([]domain.LogEntry{}, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+jobID.String()+"/trace/"+traceID, nil)
@@ -147,7 +155,7 @@ func TestTraceHandler_EmptyResults(t *testing.T) {
 func TestTraceHandler_SuccessWithResults(t *testing.T) {
 	mockCH := new(testutil.MockClickHouseStore)
 	mockRedis := new(testutil.MockRedisCache)
-	h := NewTraceHandler(mockCH, mockRedis)
+	h := NewTraceHandler(mockCH, mockRedis, 0)
 
 	jobID := uuid.New()
 	traceID := "T001"
@@ -176,7 +184,7 @@ func TestTraceHandler_SuccessWithResults(t *testing.T) {
 			DurationMS: 50,
 		},
 	}
-	mockCH.On("GetTraceEntries", mock.Anything, tenantID, jobID.String(), traceID).
+	mockCH.On("GetTraceEntriesStream", mock.Anything, tenantID, jobID.String(), traceID, storage.TraceQuery{}).
 		Return(entries, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+jobID.String()+"/trace/"+traceID, nil)
@@ -256,7 +264,7 @@ func TestTraceHandler_TableDriven(t *testing.T) {
 			jobIDStr: validJobID.String(),
 			traceID:  traceID,
 			setupMock: func(m *testutil.MockClickHouseStore) {
-				m.On("GetTraceEntries", mock.Anything, tenantID, validJobID.String(), traceID).
+				m.On("GetTraceEntriesStream", mock.Anything, tenantID, validJobID.String(), traceID, storage.TraceQuery{}).
 					Return(nil, errors.New("search failed"))
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -267,7 +275,7 @@ func TestTraceHandler_TableDriven(t *testing.T) {
 			jobIDStr: validJobID.String(),
 			traceID:  traceID,
 			setupMock: func(m *testutil.MockClickHouseStore) {
-				m.On("GetTraceEntries", mock.Anything, tenantID, validJobID.String(), traceID).
+				m.On("GetTraceEntriesStream", mock.Anything, tenantID, validJobID.String(), traceID, storage.TraceQuery{}).
 					Return([]domain.LogEntry{
 						{EntryID: "e1", DurationMS: 42},
 					}, nil)
@@ -288,7 +296,7 @@ func TestTraceHandler_TableDriven(t *testing.T) {
 			mockCH := new(testutil.MockClickHouseStore)
 			tc.setupMock(mockCH)
 
-			handler := NewTraceHandler(mockCH, nil)
+			handler := NewTraceHandler(mockCH, nil, 0)
 
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+tc.jobIDStr+"/trace/"+tc.traceID, nil)
 			if tc.tenantID != "" {
@@ -310,3 +318,228 @@ func TestTraceHandler_TableDriven(t *testing.T) {
 		})
 	}
 }
+
+func newTraceRequest(jobID uuid.UUID, traceID, query string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/"+jobID.String()+"/trace/"+traceID+query, nil)
+	ctx := middleware.WithTenantID(req.Context(), "test-tenant")
+	ctx = middleware.WithUserID(ctx, "test-user")
+	return mux.SetURLVars(req.WithContext(ctx), map[string]string{"job_id": jobID.String(), "trace_id": traceID})
+}
+
+func TestTraceHandler_Paging(t *testing.T) {
+	jobID := uuid.New()
+	base := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	entries := make([]domain.LogEntry, 3)
+	for i := range entries {
+		entries[i] = domain.LogEntry{
+			EntryID:    fmt.Sprintf("e%d", i+1),
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Second)),
+			DurationMS: 10,
+		}
+	}
+
+	mockCH := new(testutil.MockClickHouseStore)
+	mockCH.On("GetTraceEntriesStream", mock.Anything, "test-tenant", jobID.String(), "T001", storage.TraceQuery{Limit: 3}).
+		Return(entries, nil)
+	mockCH.On("GetTraceEntriesStream", mock.Anything, "test-tenant", jobID.String(), "T001",
+		storage.TraceQuery{After: &storage.TraceCursor{Timestamp: base.Add(time.Second), EntryID: "e2"}, Limit: 3}).
+		Return(entries[2:], nil)
+	h := NewTraceHandler(mockCH, nil, 10)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newTraceRequest(jobID, "T001", "?limit=2"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page struct {
+		Entries    []traceEntry `json:"entries"`
+		EntryCount int          `json:"entry_count"`
+		Truncated  bool         `json:"truncated"`
+		NextCursor string       `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Entries, 2)
+	assert.Equal(t, 2, page.EntryCount)
+	assert.True(t, page.Truncated)
+	require.NotEmpty(t, page.NextCursor)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, newTraceRequest(jobID, "T001", "?limit=2&cursor="+page.NextCursor))
+	require.Equal(t, http.StatusOK, w.Code)
+	page.NextCursor = ""
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, "e3", page.Entries[0].ID)
+	assert.False(t, page.Truncated)
+	assert.Empty(t, page.NextCursor)
+
+	mockCH.AssertExpectations(t)
+}
+
+func TestTraceHandler_InvalidPaging(t *testing.T) {
+	h := NewTraceHandler(new(testutil.MockClickHouseStore), nil, 10)
+	for _, query := range []string{"?limit=0", "?limit=abc", "?cursor=%21%21", "?cursor=" + base64.RawURLEncoding.EncodeToString([]byte("no-separator"))} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newTraceRequest(uuid.New(), "T001", query))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestTraceHandler_NDJSON(t *testing.T) {
+	jobID := uuid.New()
+	mockCH := new(testutil.MockClickHouseStore)
+	mockCH.On("GetTraceEntriesStream", mock.Anything, "test-tenant", jobID.String(), "T001", storage.TraceQuery{}).
+		Return([]domain.LogEntry{{EntryID: "e1", DurationMS: 5}, {EntryID: "e2", DurationMS: 7}}, nil)
+	h := NewTraceHandler(mockCH, nil, 0)
+
+	req := newTraceRequest(jobID, "T001", "")
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var entry traceEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "e2", entry.ID)
+
+	var last struct {
+		TraceID string       `json:"trace_id"`
+		Trailer traceTrailer `json:"trailer"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &last))
+	assert.Equal(t, "T001", last.TraceID)
+	assert.Equal(t, traceTrailer{EntryCount: 2, TotalDuration: 12}, last.Trailer)
+}
+
+func TestTraceHandler_ErrorAfterFirstEntry(t *testing.T) {
+	jobID := uuid.New()
+	mockCH := new(testutil.MockClickHouseStore)
+	mockCH.On("GetTraceEntriesStream", mock.Anything, "test-tenant", jobID.String(), "T001", storage.TraceQuery{}).
+		Return([]domain.LogEntry{{EntryID: "e1"}}, errors.New("connection reset"))
+	h := NewTraceHandler(mockCH, nil, 0)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newTraceRequest(jobID, "T001", ""))
+
+	assert.Equal(t, http.StatusOK, w.Code, "the status is sent with the first entry")
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(1), resp["entry_count"])
+	assert.Equal(t, "trace stream interrupted", resp["error"])
+}
+
+// syntheticTraceStore streams n generated entries of a trace and samples
+// the live heap while doing so.
+type syntheticTraceStore struct {
+	*testutil.MockClickHouseStore
+	n        int
+	read     int
+	peakHeap uint64
+}
+
+func (s *syntheticTraceStore) GetTraceEntriesStream(_ context.Context, _, jobID, traceID string, q storage.TraceQuery, fn func(domain.LogEntry) error) error {
+	base := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	var ms runtime.MemStats
+	for i := 0; i < s.n && (q.Limit <= 0 || i < q.Limit); i++ {
+		if i%100_000 == 0 {
+			runtime.GC()
+			runtime.ReadMemStats(&ms)
+			s.peakHeap = max(s.peakHeap, ms.HeapAlloc)
+		}
+		s.read++
+		err := fn(domain.LogEntry{
+			JobID:      jobID,
+			EntryID:    strconv.Itoa(i),
+			TraceID:    traceID,
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Microsecond)),
+			LogType:    domain.LogTypeAPI,
+			APICode:    "GE",
+			Form:       "HPD:Help Desk",
+			DurationMS: 1,
+			Success:    true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tailWriter is a ResponseWriter that counts what is written and keeps
+// only the end of it.
+type tailWriter struct {
+	header  http.Header
+	code    int
+	written int
+	flushes int
+	tail    []byte
+}
+
+func (w *tailWriter) Header() http.Header { return w.header }
+
+func (w *tailWriter) WriteHeader(code int) { w.code = code }
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	w.tail = append(w.tail, p...)
+	if len(w.tail) > 8<<10 {
+		w.tail = append(w.tail[:0], w.tail[len(w.tail)-4<<10:]...)
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) Flush() { w.flushes++ }
+
+func TestTraceHandler_StreamsLargeTrace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams a million entries")
+	}
+	const rows = 1_000_000
+	// A trace of this size takes well over 100 MB once buffered.
+	const maxHeapGrowth = 32 << 20
+
+	run := func(t *testing.T, maxEntries int) (*syntheticTraceStore, *tailWriter, map[string]interface{}) {
+		store := &syntheticTraceStore{MockClickHouseStore: new(testutil.MockClickHouseStore), n: rows}
+		w := &tailWriter{header: http.Header{}}
+		runtime.GC()
+		var before runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		NewTraceHandler(store, nil, maxEntries).ServeHTTP(w, newTraceRequest(uuid.New(), "T-big", "?format=ndjson"))
+
+		require.Equal(t, http.StatusOK, w.code)
+		if store.peakHeap > before.HeapAlloc {
+			assert.Less(t, store.peakHeap-before.HeapAlloc, uint64(maxHeapGrowth), "heap grew with the trace")
+		}
+		lines := strings.Split(strings.TrimSpace(string(w.tail)), "\n")
+		var last struct {
+			Trailer map[string]interface{} `json:"trailer"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+		return store, w, last.Trailer
+	}
+
+	t.Run("below the cap", func(t *testing.T) {
+		store, w, trailer := run(t, rows+1)
+		assert.Equal(t, rows, store.read)
+		assert.Equal(t, float64(rows), trailer["entry_count"])
+		assert.Equal(t, false, trailer["truncated"])
+		assert.NotContains(t, trailer, "next_cursor")
+		assert.GreaterOrEqual(t, w.flushes, rows/traceFlushEvery)
+	})
+
+	t.Run("truncated at the cap", func(t *testing.T) {
+		const maxEntries = 250_000
+		store, _, trailer := run(t, maxEntries)
+		assert.Equal(t, maxEntries+1, store.read, "reading stops right after the cap")
+		assert.Equal(t, float64(maxEntries), trailer["entry_count"])
+		assert.Equal(t, true, trailer["truncated"])
+
+		cursor, err := decodeTraceCursor(trailer["next_cursor"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(maxEntries-1), cursor.EntryID)
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// JobPurger permanently deletes an analysis and everything stored for it.
+// worker.JobPurger implements it.
+type JobPurger interface {
+	Purge(ctx context.Context, job *domain.AnalysisJob) error
+}
+
+// TrashHandlers move analyses to the trash and back. Deleting an analysis
+// only hides it; its data is kept until the worker purges it once the
+// grace period has passed, or right away when an administrator forces the
+// delete.
+type TrashHandlers struct {
+	pg     storage.PostgresStore
+	purger JobPurger
+	admins *middleware.AdminMiddleware
+}
+
+// NewTrashHandlers creates the trash handlers. adminUserIDs may delete
+// analyses permanently with force=true.
+func NewTrashHandlers(pg storage.PostgresStore, purger JobPurger, adminUserIDs []string) *TrashHandlers {
+	return &TrashHandlers{pg: pg, purger: purger, admins: middleware.NewAdminMiddleware(adminUserIDs)}
+}
+
+// DeleteAnalysis handles DELETE /api/v1/analysis/{job_id}?force=. The
+// analysis is moved to the trash unless force=true, which purges it at once
+// and is reserved for administrators.
+func (h *TrashHandlers) DeleteAnalysis() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobID, ok := pathID(w, r, "job_id")
+		if !ok {
+			return
+		}
+
+		userID := middleware.GetUserID(r.Context())
+		if r.URL.Query().Get("force") != "true" {
+			if err := h.pg.SoftDeleteJob(r.Context(), tid, jobID); err != nil {
+				writeJobError(w, jobID, "failed to delete analysis job", err)
+				return
+			}
+			slog.Info("analysis moved to trash", "tenant_id", tid, "job_id", jobID, "user_id", userID)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !h.admins.IsAdmin(userID) {
+			api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "permanent deletion requires administrator access")
+			return
+		}
+		// The status check of a purge must not be stale.
+		job, err := h.pg.GetJob(storage.WithPrimary(r.Context()), tid, jobID)
+		if err != nil {
+			writeJobError(w, jobID, "failed to delete analysis job", err)
+			return
+		}
+		if !job.Status.Terminal() {
+			api.Error(w, http.StatusConflict, api.ErrCodeConflict, "analysis is still running")
+			return
+		}
+		if err := h.purger.Purge(r.Context(), job); err != nil {
+			slog.Error("failed to purge analysis", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to delete analysis job")
+			return
+		}
+		slog.Info("analysis purged by administrator", "tenant_id", tid, "job_id", jobID, "user_id", userID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ListTrash handles GET /api/v1/analyses/trash, the analyses of the tenant
+// in the trash, most recently deleted first.
+func (h *TrashHandlers) ListTrash() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobs, err := h.pg.ListDeletedJobs(r.Context(), tid)
+		if err != nil {
+			slog.Error("failed to list deleted jobs", "tenant_id", tid, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list deleted analysis jobs")
+			return
+		}
+		if jobs == nil {
+			jobs = []domain.AnalysisJob{}
+		}
+		api.JSON(w, http.StatusOK, map[string]interface{}{
+			"jobs": jobs,
+			"pagination": map[string]interface{}{
+				"page":        1,
+				"page_size":   len(jobs),
+				"total_count": len(jobs),
+				"total_pages": 1,
+			},
+		})
+	})
+}
+
+// RestoreAnalysis handles POST /api/v1/analyses/{id}/restore and returns
+// the restored analysis.
+func (h *TrashHandlers) RestoreAnalysis() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, ok := requestTenant(w, r)
+		if !ok {
+			return
+		}
+		jobID, ok := pathID(w, r, "id")
+		if !ok {
+			return
+		}
+
+		if err := h.pg.RestoreJob(r.Context(), tid, jobID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found in trash")
+			} else {
+				slog.Error("failed to restore job", "job_id", jobID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to restore analysis job")
+			}
+			return
+		}
+		// Read back the restored job from the primary, which the replica
+		// may not have caught up with.
+		job, err := h.pg.GetJob(storage.WithPrimary(r.Context()), tid, jobID)
+		if err != nil {
+			writeJobError(w, jobID, "failed to retrieve analysis job", err)
+			return
+		}
+		slog.Info("analysis restored from trash", "tenant_id", tid, "job_id", jobID, "user_id", middleware.GetUserID(r.Context()))
+		api.JSON(w, http.StatusOK, job)
+	})
+}
+
+// JobGuard hides analyses in the trash from every route naming one, through
+// a job_id path variable or query parameter, by answering 404 as if they
+// did not exist.
+type JobGuard struct {
+	pg storage.PostgresStore
+}
+
+// NewJobGuard creates a JobGuard.
+func NewJobGuard(pg storage.PostgresStore) *JobGuard {
+	return &JobGuard{pg: pg}
+}
+
+// RequireLiveJob is the middleware of the guard. Malformed IDs are left to
+// the handlers to reject.
+func (g *JobGuard) RequireLiveJob(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jobID, ok := lookupID(r, "job_id")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tid, err := middleware.ParseID(middleware.GetTenantID(r.Context()))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if _, err := g.pg.GetJob(r.Context(), tid, jobID); err != nil {
+			writeJobError(w, jobID, "failed to retrieve analysis job", err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJobError answers 404 for a job that does not exist or is in the
+// trash and 500 with msg otherwise.
+func writeJobError(w http.ResponseWriter, jobID uuid.UUID, msg string, err error) {
+	if storage.IsNotFound(err) {
+		api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+		return
+	}
+	slog.Error(msg, "job_id", jobID, "error", err)
+	api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, msg)
+}
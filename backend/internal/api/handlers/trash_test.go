@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+type stubPurger struct {
+	purged []uuid.UUID
+	err    error
+}
+
+func (p *stubPurger) Purge(_ context.Context, job *domain.AnalysisJob) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.purged = append(p.purged, job.ID)
+	return nil
+}
+
+func jobNotFound() error {
+	return fmt.Errorf("postgres: job not found: %s", fixedJobID)
+}
+
+func TestTrashHandlers_DeleteAnalysis(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		admins     []string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		purgeErr   error
+		wantStatus int
+		wantPurged bool
+	}{
+		{
+			name:  "moves the analysis to the trash",
+			query: "",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("SoftDeleteJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "unknown analysis",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("SoftDeleteJob", mock.Anything, fixedTenantID, fixedJobID).Return(jobNotFound())
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "force requires an administrator",
+			query:      "?force=true",
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "administrator purges a finished analysis",
+			query:  "?force=true",
+			admins: []string{"test-user"},
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}, nil)
+			},
+			wantStatus: http.StatusNoContent,
+			wantPurged: true,
+		},
+		{
+			name:   "running analyses cannot be purged",
+			query:  "?force=true",
+			admins: []string{"test-user"},
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusParsing}, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:   "purge failure",
+			query:  "?force=true",
+			admins: []string{"test-user"},
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+					Return(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusFailed}, nil)
+			},
+			purgeErr:   errors.New("clickhouse down"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+			purger := &stubPurger{err: tc.purgeErr}
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/analysis/"+fixedJobID.String()+tc.query, nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+			w := httptest.NewRecorder()
+			NewTrashHandlers(pg, purger, tc.admins).DeleteAnalysis().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantPurged {
+				assert.Equal(t, []uuid.UUID{fixedJobID}, purger.purged)
+			} else {
+				assert.Empty(t, purger.purged)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTrashHandlers_ListTrash(t *testing.T) {
+	deletedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListDeletedJobs", mock.Anything, fixedTenantID).
+		Return([]domain.AnalysisJob{{ID: fixedJobID, TenantID: fixedTenantID, DeletedAt: &deletedAt}}, nil)
+
+	req := injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/analyses/trash", nil), fixedTenantID.String())
+	w := httptest.NewRecorder()
+	NewTrashHandlers(pg, &stubPurger{}, nil).ListTrash().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Jobs []domain.AnalysisJob `json:"jobs"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Jobs, 1)
+	require.NotNil(t, resp.Jobs[0].DeletedAt)
+	assert.True(t, deletedAt.Equal(*resp.Jobs[0].DeletedAt))
+}
+
+func TestTrashHandlers_RestoreAnalysis(t *testing.T) {
+	t.Run("restores and returns the analysis", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("RestoreJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil)
+		pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+			Return(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/analyses/"+fixedJobID.String()+"/restore", nil)
+		req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"id": fixedJobID.String()})
+		w := httptest.NewRecorder()
+		NewTrashHandlers(pg, &stubPurger{}, nil).RestoreAnalysis().ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var job domain.AnalysisJob
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&job))
+		assert.Equal(t, fixedJobID, job.ID)
+		assert.Nil(t, job.DeletedAt)
+	})
+
+	t.Run("not in the trash", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("RestoreJob", mock.Anything, fixedTenantID, fixedJobID).
+			Return(fmt.Errorf("postgres: deleted job not found: %s", fixedJobID))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/analyses/"+fixedJobID.String()+"/restore", nil)
+		req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"id": fixedJobID.String()})
+		w := httptest.NewRecorder()
+		NewTrashHandlers(pg, &stubPurger{}, nil).RestoreAnalysis().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "not_found", decodeError(t, w).Code)
+	})
+}
+
+func TestJobGuard_HidesTrashedAnalyses(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(nil, jobNotFound())
+
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r := mux.NewRouter()
+	r.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(w, injectAuth(req, fixedTenantID.String()))
+		})
+	})
+	r.Use(NewJobGuard(pg).RequireLiveJob)
+	for _, path := range []string{
+		"/analysis/{job_id}",
+		"/analysis/{job_id}/dashboard",
+		"/analysis/{job_id}/search",
+		"/analysis/{job_id}/entries/{entry_id}",
+		"/analysis/{job_id}/trace/{trace_id}",
+		"/search/autocomplete",
+		"/ai/conversations",
+	} {
+		r.Handle(path, next)
+	}
+
+	job := fixedJobID.String()
+	for _, url := range []string{
+		"/analysis/" + job,
+		"/analysis/" + job + "/dashboard",
+		"/analysis/" + job + "/search?q=error",
+		"/analysis/" + job + "/entries/entry-1",
+		"/analysis/" + job + "/trace/trace-1",
+		"/search/autocomplete?field=user&job_id=" + job,
+		"/ai/conversations?job_id=" + job,
+	} {
+		t.Run(url, func(t *testing.T) {
+			reached = false
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.False(t, reached)
+		})
+	}
+
+	t.Run("routes without a job pass through", func(t *testing.T) {
+		reached = false
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/autocomplete?field=user", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, reached)
+	})
+}
+
+func TestJobGuard_LiveAnalysisPassesThrough(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).
+		Return(&domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/analysis/"+fixedJobID.String()+"/search", nil)
+	req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+	w := httptest.NewRecorder()
+	NewJobGuard(pg).RequireLiveJob(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	pg.AssertExpectations(t)
+}
@@ -10,26 +10,29 @@ import (
 	"os"
 	"path"
 	"strings"
-	"time"
-
-	"github.com/google/uuid"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/logsource"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/usage"
 )
 
 const maxUploadSize = 2 << 30 // 2 GB
 
 // UploadHandler handles POST /api/v1/files/upload.
+// Uploaded bytes are accounted to the tenant through usage.
 type UploadHandler struct {
-	pg storage.PostgresStore
-	s3 storage.S3Storage
+	pg    storage.PostgresStore
+	s3    storage.ObjectStorage
+	usage *usage.Recorder
+
+	stamps
 }
 
-func NewUploadHandler(pg storage.PostgresStore, s3 storage.S3Storage) *UploadHandler {
-	return &UploadHandler{pg: pg, s3: s3}
+func NewUploadHandler(pg storage.PostgresStore, s3 storage.ObjectStorage, usage *usage.Recorder) *UploadHandler {
+	return &UploadHandler{pg: pg, s3: s3, usage: usage}
 }
 
 func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -59,13 +62,21 @@ func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// Validate tenant ID format before uploading to S3 to avoid orphan objects.
-	tid, err := uuid.Parse(tenantID)
-	if err != nil {
-		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid tenant_id format")
+	tid, ok := requestTenant(w, r)
+	if !ok {
 		return
 	}
 
-	// Detect log types from filename.
+	// The source type defaults to an AR Server log, whose log types are
+	// detected from the filename.
+	sourceType := domain.LogSourceType(r.FormValue("source_type"))
+	if sourceType == "" {
+		sourceType = domain.LogSourceARServer
+	}
+	if !sourceType.Valid() {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid source_type: "+string(sourceType))
+		return
+	}
 	detectedTypes := detectLogTypes(header.Filename)
 
 	// Buffer to a temp file so the AWS SDK can seek for payload hash computation.
@@ -87,6 +98,35 @@ func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Logs of other sources must look like what the upload says they are.
+	if source, ok := logsource.Lookup(sourceType); ok {
+		head := make([]byte, logsource.HeaderBytes)
+		n, err := tmpFile.ReadAt(head, 0)
+		if err != nil && err != io.EOF {
+			slog.Error("failed to read upload header", "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to process upload")
+			return
+		}
+		if !source.Detect(head[:n]) {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "file is not a "+string(sourceType)+" log")
+			return
+		}
+		detectedTypes = []string{string(sourceLogType(sourceType))}
+	}
+
+	// Sandbox tenants are held to their own quotas, and upload nothing once
+	// expired.
+	tenant := lookupTenant(r.Context(), h.pg, tid)
+	if tenant.SandboxExpired(h.now()) {
+		api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "sandbox tenant has expired")
+		return
+	}
+	if limit := tenant.Quotas(domain.TenantQuotas{MaxFileBytes: maxUploadSize}).MaxFileBytes; limit > 0 && size > limit {
+		api.Error(w, http.StatusRequestEntityTooLarge, api.ErrCodeFileTooLarge,
+			fmt.Sprintf("file exceeds the tenant's limit of %d MB", limit>>20))
+		return
+	}
+
 	// Seek back to the start for the S3 upload.
 	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
 		slog.Error("failed to seek temp file", "error", err)
@@ -95,7 +135,7 @@ func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate S3 key and upload.
-	fileID := uuid.New()
+	fileID := h.newID()
 	s3Key := path.Join("tenants", tenantID, "jobs", fileID.String(), header.Filename)
 
 	if err := h.s3.Upload(r.Context(), s3Key, tmpFile, size); err != nil {
@@ -114,7 +154,8 @@ func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ContentType:    header.Header.Get("Content-Type"),
 		DetectedTypes:  detectedTypes,
 		ChecksumSHA256: fmt.Sprintf("%x", hasher.Sum(nil)),
-		UploadedAt:     time.Now().UTC(),
+		UploadedAt:     h.now(),
+		SourceType:     sourceType,
 	}
 
 	if err := h.pg.CreateLogFile(r.Context(), logFile); err != nil {
@@ -122,6 +163,13 @@ func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to save file metadata")
 		return
 	}
+	h.usage.Record(domain.UsageEvent{
+		TenantID:   tid,
+		Metric:     domain.UsageBytesUploaded,
+		SourceID:   logFile.ID,
+		Amount:     logFile.SizeBytes,
+		OccurredAt: logFile.UploadedAt,
+	})
 
 	api.JSON(w, http.StatusCreated, logFile)
 }
@@ -150,3 +198,11 @@ func detectLogTypes(filename string) []string {
 	}
 	return types
 }
+
+// sourceLogType is the log type of the entries of a non-AR log source.
+func sourceLogType(t domain.LogSourceType) domain.LogType {
+	if t == domain.LogSourceJVMGC {
+		return domain.LogTypeGC
+	}
+	return domain.LogTypeHTTP
+}
@@ -11,7 +11,10 @@ import (
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -97,7 +100,7 @@ func TestDetectLogTypes_CaseInsensitive(t *testing.T) {
 
 func TestUploadHandler_MissingTenantContext(t *testing.T) {
 	// nil storage clients is fine -- we will not reach storage operations
-	h := NewUploadHandler(nil, nil)
+	h := NewUploadHandler(nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", nil)
 	// No tenant context set
@@ -112,7 +115,7 @@ func TestUploadHandler_MissingTenantContext(t *testing.T) {
 }
 
 func TestUploadHandler_InvalidMultipartForm(t *testing.T) {
-	h := NewUploadHandler(nil, nil)
+	h := NewUploadHandler(nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", bytes.NewBufferString("not a multipart form"))
 	req.Header.Set("Content-Type", "text/plain")
@@ -130,7 +133,7 @@ func TestUploadHandler_InvalidMultipartForm(t *testing.T) {
 }
 
 func TestUploadHandler_MissingFileField(t *testing.T) {
-	h := NewUploadHandler(nil, nil)
+	h := NewUploadHandler(nil, nil, nil)
 
 	// Create a valid multipart form without the "file" field
 	var body bytes.Buffer
@@ -153,3 +156,66 @@ func TestUploadHandler_MissingFileField(t *testing.T) {
 	assert.Equal(t, api.ErrCodeInvalidRequest, errResp.Code)
 	assert.Contains(t, errResp.Message, "file")
 }
+
+// newSourceUploadRequest builds an upload of content as filename with the
+// given source_type field.
+func newSourceUploadRequest(t *testing.T, filename, sourceType, content string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("source_type", sourceType))
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req.WithContext(context.WithValue(req.Context(), middleware.TenantIDKey, fixedTenantID.String()))
+}
+
+func TestUploadHandler_SourceType(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	noTenantRecord(pg)
+	s3 := &testutil.MockObjectStorage{}
+	s3.On("Upload", mock.Anything, mock.AnythingOfType("string"), mock.Anything, mock.AnythingOfType("int64")).Return(nil)
+	pg.On("CreateLogFile", mock.Anything, mock.MatchedBy(func(f *domain.LogFile) bool {
+		return f.SourceType == domain.LogSourceJVMGC
+	})).Return(nil)
+
+	gcLog := "[2025-10-10T13:55:30.158+0000][30.169s][info][gc] GC(41) Pause Young (Normal) (G1 Evacuation Pause) 512M->128M(2048M) 35.123ms\n"
+	w := httptest.NewRecorder()
+	NewUploadHandler(pg, s3, nil).ServeHTTP(w, newSourceUploadRequest(t, "gc.log", "jvm_gc", gcLog))
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var got domain.LogFile
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, domain.LogSourceJVMGC, got.SourceType)
+	assert.Equal(t, []string{"GC"}, got.DetectedTypes)
+	pg.AssertExpectations(t)
+	s3.AssertExpectations(t)
+}
+
+func TestUploadHandler_SourceTypeRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceType string
+		content    string
+		wantMsg    string
+	}{
+		{"unknown source type", "iis", "line\n", "invalid source_type"},
+		{"content of another source", "tomcat_access", "[2025-10-10T13:55:30.158+0000][info][gc] GC(41) Pause Remark 2.345ms\n", "not a tomcat_access log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := &testutil.MockObjectStorage{}
+			w := httptest.NewRecorder()
+			NewUploadHandler(nil, s3, nil).ServeHTTP(w, newSourceUploadRequest(t, "access.log", tt.sourceType, tt.content))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantMsg)
+			s3.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
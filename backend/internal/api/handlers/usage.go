@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// defaultUsageMonths is the number of months returned when from is
+	// omitted, including the current one.
+	defaultUsageMonths = 12
+
+	// maxUsageMonths caps the months of a single usage request.
+	maxUsageMonths = 60
+)
+
+// UploadLimitsSource reports the state of the upload limits.
+// middleware.UploadLimiter implements it.
+type UploadLimitsSource interface {
+	TenantUploadLimits(ctx context.Context, tenantID string) *domain.UploadLimits
+	UploadLimits() *domain.UploadLimits
+}
+
+// UsageHandlers serve the usage accounted per tenant and calendar month:
+// bytes uploaded, analyses run, rows stored, worker time and AI queries.
+type UsageHandlers struct {
+	pg      storage.PostgresStore
+	cache   storage.CacheUsageStore
+	uploads UploadLimitsSource
+	admins  *middleware.AdminMiddleware
+	now     func() time.Time
+}
+
+// NewUsageHandlers creates the usage handlers. adminUserIDs may read the
+// usage of every tenant; other users only that of their own. When cache is
+// not nil, the responses also report the Redis cache usage, and when
+// uploads is not nil the state of the upload limits.
+func NewUsageHandlers(pg storage.PostgresStore, cache storage.CacheUsageStore, uploads UploadLimitsSource, adminUserIDs []string) *UsageHandlers {
+	return &UsageHandlers{pg: pg, cache: cache, uploads: uploads, admins: middleware.NewAdminMiddleware(adminUserIDs), now: time.Now}
+}
+
+// TenantUsage handles GET /api/v1/tenants/{tenant_id}/usage?from=&to=, where
+// from and to are months formatted YYYY-MM. By default the last twelve months
+// are returned.
+func (h *UsageHandlers) TenantUsage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ok := pathID(w, r, "tenant_id")
+		if !ok {
+			return
+		}
+		if tenantID.String() != middleware.GetTenantID(r.Context()) && !h.admins.IsAdmin(middleware.GetUserID(r.Context())) {
+			api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "usage of another tenant requires administrator access")
+			return
+		}
+		h.serve(w, r, &tenantID, true)
+	})
+}
+
+// AllTenantsUsage handles GET /api/v1/admin/usage?from=&to=, the usage
+// summed over every tenant. Sandbox tenants are left out of the sums and
+// of the cache usage unless include_sandbox=true.
+func (h *UsageHandlers) AllTenantsUsage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, nil, r.URL.Query().Get("include_sandbox") == "true")
+	})
+}
+
+func (h *UsageHandlers) serve(w http.ResponseWriter, r *http.Request, tenantID *uuid.UUID, includeSandbox bool) {
+	now := h.now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	to := current
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse("2006-01", v)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "to must be a month formatted YYYY-MM")
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, -(defaultUsageMonths - 1), 0)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse("2006-01", v)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "from must be a month formatted YYYY-MM")
+			return
+		}
+		from = t
+	}
+	if from.After(to) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "from must not be after to")
+		return
+	}
+	if from.AddDate(0, maxUsageMonths, 0).Before(to.AddDate(0, 1, 0)) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "at most 60 months can be requested")
+		return
+	}
+
+	months, err := h.pg.GetTenantUsage(r.Context(), tenantID, from, to, includeSandbox)
+	if err != nil {
+		slog.Error("get tenant usage failed", "tenant_id", tenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve usage")
+		return
+	}
+	mtd, err := h.pg.GetTenantUsage(r.Context(), tenantID, current, current, includeSandbox)
+	if err != nil || len(mtd) != 1 {
+		slog.Error("get month-to-date usage failed", "tenant_id", tenantID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve usage")
+		return
+	}
+
+	resp := domain.TenantUsage{
+		TenantID:    tenantID,
+		From:        from.Format("2006-01"),
+		To:          to.Format("2006-01"),
+		Months:      months,
+		MonthToDate: mtd[0],
+	}
+	h.addCacheUsage(r, &resp, includeSandbox)
+	if h.uploads != nil {
+		if tenantID != nil {
+			resp.Uploads = h.uploads.TenantUploadLimits(r.Context(), tenantID.String())
+		} else {
+			resp.Uploads = h.uploads.UploadLimits()
+		}
+	}
+	api.JSON(w, http.StatusOK, resp)
+}
+
+// addCacheUsage adds the current cache usage to resp: that of the tenant, or
+// that of every tenant for the admin summary. The cache usage is best
+// effort and left out when it cannot be read, as is that of every tenant
+// when the sandbox tenants to leave out of it cannot be told apart.
+func (h *UsageHandlers) addCacheUsage(r *http.Request, resp *domain.TenantUsage, includeSandbox bool) {
+	if h.cache == nil {
+		return
+	}
+	if resp.TenantID != nil {
+		cache, err := h.cache.TenantCacheUsage(r.Context(), resp.TenantID.String())
+		if err != nil {
+			slog.Warn("get tenant cache usage failed", "tenant_id", resp.TenantID, "error", err)
+			return
+		}
+		resp.Cache = cache
+		return
+	}
+	caches, err := h.cache.AllTenantsCacheUsage(r.Context())
+	if err != nil {
+		slog.Warn("get cache usage failed", "error", err)
+		return
+	}
+	if !includeSandbox {
+		tenants, err := h.pg.ListTenants(r.Context())
+		if err != nil {
+			slog.Warn("list tenants for cache usage failed", "error", err)
+			return
+		}
+		sandboxes := make(map[string]bool)
+		for _, t := range tenants {
+			if t.Sandbox {
+				sandboxes[t.ID.String()] = true
+			}
+		}
+		kept := caches[:0]
+		for _, c := range caches {
+			if !sandboxes[c.TenantID] {
+				kept = append(kept, c)
+			}
+		}
+		caches = kept
+	}
+	resp.Caches = caches
+}
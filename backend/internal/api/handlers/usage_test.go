@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestUsageHandlers_TenantUsage(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	october := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	mtd := []domain.UsageMonth{{Month: "2026-10", UsageTotals: domain.UsageTotals{AnalysesRun: 4, BytesUploaded: 1 << 20}}}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		query      string
+		admins     []string
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "own tenant defaults to the last twelve months",
+			tenant: fixedTenantID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenantUsage", mock.Anything, &fixedTenantID, time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC), october, true).
+					Return([]domain.UsageMonth{{Month: "2025-11"}, {Month: "2026-10"}}, nil)
+				pg.On("GetTenantUsage", mock.Anything, &fixedTenantID, october, october, true).Return(mtd, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var resp domain.TenantUsage
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Equal(t, "2025-11", resp.From)
+				assert.Equal(t, "2026-10", resp.To)
+				assert.Equal(t, &fixedTenantID, resp.TenantID)
+				assert.Len(t, resp.Months, 2)
+				assert.Equal(t, mtd[0], resp.MonthToDate)
+			},
+		},
+		{
+			name:   "explicit range",
+			tenant: fixedTenantID.String(),
+			query:  "?from=2026-01&to=2026-03",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenantUsage", mock.Anything, &fixedTenantID,
+					time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), true).
+					Return([]domain.UsageMonth{{Month: "2026-01"}, {Month: "2026-02"}, {Month: "2026-03"}}, nil)
+				pg.On("GetTenantUsage", mock.Anything, &fixedTenantID, october, october, true).Return(mtd, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "other tenant refused",
+			tenant:     fixedJobID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "admin reads other tenant",
+			tenant: fixedJobID.String(),
+			admins: []string{"test-user"},
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenantUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mtd, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid month",
+			tenant:     fixedTenantID.String(),
+			query:      "?from=2026-13",
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "from after to",
+			tenant:     fixedTenantID.String(),
+			query:      "?from=2026-05&to=2026-04",
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "range too long",
+			tenant:     fixedTenantID.String(),
+			query:      "?from=2020-01&to=2026-01",
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, "60 months")
+			},
+		},
+		{
+			name:       "invalid tenant id",
+			tenant:     "nope",
+			setupMocks: func(pg *testutil.MockPostgresStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "store failure",
+			tenant: fixedTenantID.String(),
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("GetTenantUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			tc.setupMocks(pg)
+
+			h := NewUsageHandlers(pg, nil, nil, tc.admins)
+			h.now = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/tenants/"+tc.tenant+"/usage"+tc.query, nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"tenant_id": tc.tenant})
+			w := httptest.NewRecorder()
+			h.TenantUsage().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsageHandlers_AllTenantsUsage(t *testing.T) {
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		query          string
+		includeSandbox bool
+	}{
+		{query: "", includeSandbox: false},
+		{query: "&include_sandbox=true", includeSandbox: true},
+	} {
+		t.Run(fmt.Sprintf("include_sandbox=%t", tc.includeSandbox), func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			pg.On("GetTenantUsage", mock.Anything, (*uuid.UUID)(nil), march, march, tc.includeSandbox).
+				Return([]domain.UsageMonth{{Month: "2026-03", UsageTotals: domain.UsageTotals{AIQueries: 7}}}, nil)
+
+			h := NewUsageHandlers(pg, nil, nil, nil)
+			h.now = func() time.Time { return march.Add(48 * time.Hour) }
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/usage?from=2026-03&to=2026-03"+tc.query, nil)
+			w := httptest.NewRecorder()
+			h.AllTenantsUsage().ServeHTTP(w, injectAuth(req, fixedTenantID.String()))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var resp domain.TenantUsage
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Nil(t, resp.TenantID)
+			assert.Equal(t, int64(7), resp.MonthToDate.AIQueries)
+			pg.AssertNumberOfCalls(t, "GetTenantUsage", 2)
+		})
+	}
+}
+
+func TestUsageHandlers_CacheUsage(t *testing.T) {
+	const sandboxCacheTenant = "5d2f4f0e-8a55-4a3c-9d41-2f6f3a1b7c01"
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	month := []domain.UsageMonth{{Month: "2026-03"}}
+
+	serve := func(t *testing.T, cache *testutil.MockCacheUsageStore, tenant bool) domain.TenantUsage {
+		t.Helper()
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetTenantUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(month, nil)
+		pg.On("ListTenants", mock.Anything).Return([]domain.Tenant{{ID: uuid.MustParse(sandboxCacheTenant), Sandbox: true}}, nil).Maybe()
+		h := NewUsageHandlers(pg, cache, nil, nil)
+		h.now = func() time.Time { return march }
+
+		w := httptest.NewRecorder()
+		if tenant {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/tenants/"+fixedTenantID.String()+"/usage", nil)
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"tenant_id": fixedTenantID.String()})
+			h.TenantUsage().ServeHTTP(w, req)
+		} else {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/usage", nil)
+			h.AllTenantsUsage().ServeHTTP(w, injectAuth(req, fixedTenantID.String()))
+		}
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp domain.TenantUsage
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		cache.AssertExpectations(t)
+		return resp
+	}
+
+	t.Run("tenant", func(t *testing.T) {
+		cache := new(testutil.MockCacheUsageStore)
+		usage := &domain.TenantCacheUsage{TenantID: fixedTenantID.String(), Bytes: 300, BudgetBytes: 1000,
+			Namespaces: map[string]int64{"dashboard": 300}}
+		cache.On("TenantCacheUsage", mock.Anything, fixedTenantID.String()).Return(usage, nil)
+
+		resp := serve(t, cache, true)
+		assert.Equal(t, usage, resp.Cache)
+		assert.Empty(t, resp.Caches)
+	})
+
+	t.Run("all tenants", func(t *testing.T) {
+		cache := new(testutil.MockCacheUsageStore)
+		all := []domain.TenantCacheUsage{{TenantID: "a", Bytes: 9, Namespaces: map[string]int64{"search": 9}}}
+		cache.On("AllTenantsCacheUsage", mock.Anything).Return(append(all, domain.TenantCacheUsage{TenantID: sandboxCacheTenant, Bytes: 4}), nil)
+
+		resp := serve(t, cache, false)
+		assert.Equal(t, all, resp.Caches, "sandbox tenants are left out")
+		assert.Nil(t, resp.Cache)
+	})
+
+	t.Run("unavailable cache usage is left out", func(t *testing.T) {
+		cache := new(testutil.MockCacheUsageStore)
+		cache.On("TenantCacheUsage", mock.Anything, fixedTenantID.String()).Return(nil, errors.New("redis down"))
+
+		resp := serve(t, cache, true)
+		assert.Nil(t, resp.Cache)
+	})
+}
+
+// fakeUploadLimits is an UploadLimitsSource.
+type fakeUploadLimits struct{}
+
+func (fakeUploadLimits) TenantUploadLimits(ctx context.Context, tenantID string) *domain.UploadLimits {
+	inFlight := 2
+	return &domain.UploadLimits{MaxConcurrent: 3, InFlight: &inFlight, BytesPerSec: 1 << 20}
+}
+
+func (fakeUploadLimits) UploadLimits() *domain.UploadLimits {
+	return &domain.UploadLimits{MaxConcurrent: 3, GlobalBytesPerSec: 50 << 20, ActiveStreams: 4}
+}
+
+func TestUsageHandlers_UploadLimits(t *testing.T) {
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetTenantUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]domain.UsageMonth{{Month: "2026-03"}}, nil)
+	h := NewUsageHandlers(pg, nil, fakeUploadLimits{}, nil)
+	h.now = func() time.Time { return march }
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tenants/"+fixedTenantID.String()+"/usage", nil)
+	req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"tenant_id": fixedTenantID.String()})
+	w := httptest.NewRecorder()
+	h.TenantUsage().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp domain.TenantUsage
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Uploads)
+	require.NotNil(t, resp.Uploads.InFlight)
+	assert.Equal(t, 2, *resp.Uploads.InFlight)
+	assert.Equal(t, int64(1<<20), resp.Uploads.BytesPerSec)
+
+	w = httptest.NewRecorder()
+	h.AllTenantsUsage().ServeHTTP(w, injectAuth(httptest.NewRequest(http.MethodGet, "/api/v1/admin/usage", nil), fixedTenantID.String()))
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = domain.TenantUsage{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Uploads)
+	assert.Equal(t, int64(50<<20), resp.Uploads.GlobalBytesPerSec)
+	assert.Equal(t, 4, resp.Uploads.ActiveStreams)
+	assert.Nil(t, resp.Uploads.InFlight, "uploads in flight are counted per tenant")
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	defaultVocabularyLimit = 20
+	maxVocabularyLimit     = 100
+)
+
+// VocabularyHandler serves typeahead over the form, filter, table, queue and
+// escalation names seen across all analyses of the tenant, for pickers that
+// are not tied to one analysis.
+type VocabularyHandler struct {
+	pg storage.PostgresStore
+}
+
+func NewVocabularyHandler(pg storage.PostgresStore) *VocabularyHandler {
+	return &VocabularyHandler{pg: pg}
+}
+
+// ServeHTTP handles GET /api/v1/vocabulary?field=form&prefix=HPD&limit=20.
+// Values starting with prefix come first, followed by values containing it
+// unless match=prefix is given.
+func (h *VocabularyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	field := q.Get("field")
+	if !domain.IsVocabularyField(field) {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+			"field must be one of: "+strings.Join(domain.VocabularyFields, ", "))
+		return
+	}
+
+	prefixOnly := false
+	switch q.Get("match") {
+	case "", "contains":
+	case "prefix":
+		prefixOnly = true
+	default:
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "match must be prefix or contains")
+		return
+	}
+
+	limit := defaultVocabularyLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxVocabularyLimit {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+				"limit must be between 1 and "+strconv.Itoa(maxVocabularyLimit))
+			return
+		}
+		limit = n
+	}
+
+	prefix := strings.TrimSpace(q.Get("prefix"))
+	terms, err := h.pg.SearchVocabulary(r.Context(), tid, field, prefix, prefixOnly, limit)
+	if err != nil {
+		slog.Error("failed to search vocabulary", "tenant_id", tid, "field", field, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to search vocabulary")
+		return
+	}
+	if terms == nil {
+		terms = []domain.VocabularyTerm{}
+	}
+	api.JSON(w, http.StatusOK, map[string]interface{}{
+		"field":  field,
+		"values": terms,
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestVocabularyHandler_ServeHTTP(t *testing.T) {
+	terms := []domain.VocabularyTerm{
+		{Field: "form", Value: "HPD:Help Desk", Occurrences: 200, JobCount: 2, LastJobID: fixedJobID},
+		{Field: "form", Value: "HPD:WorkLog", Occurrences: 30, JobCount: 1, LastJobID: fixedJobID},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		noTenant   bool
+		setupMocks func(pg *testutil.MockPostgresStore)
+		wantStatus int
+		wantValues int
+	}{
+		{
+			name:  "prefix and contains matches",
+			query: "field=form&prefix=HPD",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("SearchVocabulary", mock.Anything, fixedTenantID, "form", "HPD", false, defaultVocabularyLimit).Return(terms, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantValues: 2,
+		},
+		{
+			name:  "prefix matches only with a limit",
+			query: "field=filter_name&prefix=%20HPD:&match=prefix&limit=5",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("SearchVocabulary", mock.Anything, fixedTenantID, "filter_name", "HPD:", true, 5).Return(nil, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantValues: 0,
+		},
+		{
+			name:       "unknown field",
+			query:      "field=user&prefix=a",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing field",
+			query:      "prefix=a",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid match",
+			query:      "field=form&match=fuzzy",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "limit out of range",
+			query:      "field=form&limit=500",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "store failure",
+			query: "field=queue",
+			setupMocks: func(pg *testutil.MockPostgresStore) {
+				pg.On("SearchVocabulary", mock.Anything, fixedTenantID, "queue", "", false, defaultVocabularyLimit).Return(nil, errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "missing tenant",
+			query:      "field=form",
+			noTenant:   true,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			if tt.setupMocks != nil {
+				tt.setupMocks(pg)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/vocabulary?"+tt.query, nil)
+			if !tt.noTenant {
+				req = injectAuth(req, fixedTenantID.String())
+			}
+			w := httptest.NewRecorder()
+			NewVocabularyHandler(pg).ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			pg.AssertExpectations(t)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Field  string                  `json:"field"`
+				Values []domain.VocabularyTerm `json:"values"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.NotNil(t, resp.Values)
+			assert.Len(t, resp.Values, tt.wantValues)
+		})
+	}
+}
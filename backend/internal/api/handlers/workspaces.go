@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/workspace"
+)
+
+// maxWorkspacesPerUser caps the workspaces a user owns on one analysis.
+const maxWorkspacesPerUser = 50
+
+// workspaceRequest is the body of a workspace create or update. On update
+// Version is the workspace version the client last read, and absent fields
+// are left unchanged; State replaces the whole document. SchemaVersion is
+// the layout State is written in, by default the current one.
+type workspaceRequest struct {
+	Version       *int                     `json:"version"`
+	Name          *string                  `json:"name"`
+	Sharing       *domain.WorkspaceSharing `json:"sharing"`
+	SchemaVersion *int                     `json:"schema_version"`
+	State         json.RawMessage          `json:"state"`
+}
+
+// WorkspaceHandlers provides HTTP handlers for the investigation
+// workspaces of an analysis. A private workspace is seen by its owner
+// only; a read_only one by the whole tenant and changed by its owner; a
+// collaborative one is seen and changed by the whole tenant. Only the
+// owner changes sharing or deletes a workspace. Workspaces a user may not
+// see answer 404.
+type WorkspaceHandlers struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+// NewWorkspaceHandlers creates the workspace handlers. Pinned entries are
+// summarised from ch.
+func NewWorkspaceHandlers(pg storage.PostgresStore, ch storage.ClickHouseStore) *WorkspaceHandlers {
+	return &WorkspaceHandlers{pg: pg, ch: ch}
+}
+
+// caller resolves the tenant, job and user of a workspace request,
+// writing the error response when any is missing or malformed.
+func (h *WorkspaceHandlers) caller(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, string, bool) {
+	tid, ok := requestTenant(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		api.Error(w, http.StatusUnauthorized, api.ErrCodeUnauthorized, "missing user context")
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	jobID, ok := pathID(w, r, "job_id")
+	if !ok {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	return tid, jobID, userID, true
+}
+
+// workspace loads the workspace named by the path for userID, upgrading
+// its state to the current schema, and writes the error response when it
+// cannot be seen.
+func (h *WorkspaceHandlers) workspace(w http.ResponseWriter, r *http.Request, tid, jobID uuid.UUID, userID string) (*domain.Workspace, domain.WorkspaceState, bool) {
+	var state domain.WorkspaceState
+	wsID, ok := pathID(w, r, "workspace_id")
+	if !ok {
+		return nil, state, false
+	}
+	ws, err := h.pg.GetWorkspace(r.Context(), tid, jobID, wsID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "workspace not found")
+		} else {
+			slog.Error("failed to retrieve workspace", "workspace_id", wsID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve workspace")
+		}
+		return nil, state, false
+	}
+	if ws.OwnerID != userID && ws.Sharing == domain.WorkspacePrivate {
+		api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "workspace not found")
+		return nil, state, false
+	}
+	state, err = workspace.Load(ws)
+	if err != nil {
+		slog.Error("stored workspace state is invalid", "workspace_id", wsID, "schema_version", ws.SchemaVersion, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to read workspace state")
+		return nil, state, false
+	}
+	return ws, state, true
+}
+
+// Workspaces handles GET and POST /api/v1/analyses/{job_id}/workspaces:
+// the workspaces of the analysis the caller owns or that are shared with
+// the tenant, most recently updated first, and the creation of one.
+func (h *WorkspaceHandlers) Workspaces() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, jobID, userID, ok := h.caller(w, r)
+		if !ok {
+			return
+		}
+		if _, err := h.pg.GetJob(r.Context(), tid, jobID); err != nil {
+			if storage.IsNotFound(err) {
+				api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "analysis job not found")
+			} else {
+				slog.Error("failed to retrieve job", "job_id", jobID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to retrieve analysis job")
+			}
+			return
+		}
+
+		workspaces, err := h.pg.ListWorkspaces(r.Context(), tid, jobID, userID)
+		if err != nil {
+			slog.Error("failed to list workspaces", "job_id", jobID, "error", err)
+			api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to list workspaces")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			for i := range workspaces {
+				if _, err := workspace.Load(&workspaces[i]); err != nil {
+					slog.Warn("stored workspace state is invalid", "workspace_id", workspaces[i].ID, "error", err)
+				}
+			}
+			api.JSON(w, http.StatusOK, map[string]any{"job_id": jobID.String(), "workspaces": workspaces})
+		case http.MethodPost:
+			owned := 0
+			for _, ws := range workspaces {
+				if ws.OwnerID == userID {
+					owned++
+				}
+			}
+			if owned >= maxWorkspacesPerUser {
+				api.Error(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest,
+					fmt.Sprintf("at most %d workspaces per user on an analysis", maxWorkspacesPerUser))
+				return
+			}
+			h.create(w, r, tid, jobID, userID)
+		default:
+			api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+		}
+	})
+}
+
+func (h *WorkspaceHandlers) create(w http.ResponseWriter, r *http.Request, tid, jobID uuid.UUID, userID string) {
+	req, ok := decodeWorkspaceRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Name == nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "name is required")
+		return
+	}
+	ws := &domain.Workspace{
+		TenantID:  tid,
+		JobID:     jobID,
+		OwnerID:   userID,
+		Sharing:   domain.WorkspacePrivate,
+		UpdatedBy: userID,
+	}
+	state, ok := applyWorkspaceRequest(w, ws, domain.WorkspaceState{}, req)
+	if !ok {
+		return
+	}
+
+	if err := h.pg.CreateWorkspace(r.Context(), ws); err != nil {
+		slog.Error("failed to create workspace", "job_id", jobID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to create workspace")
+		return
+	}
+	api.JSON(w, http.StatusCreated, workspace.Hydrate(r.Context(), h.ch, *ws, state))
+}
+
+// Workspace handles GET, PATCH and DELETE
+// /api/v1/analyses/{job_id}/workspaces/{workspace_id}. GET returns the
+// workspace with its pins and queries hydrated. PATCH only applies if the
+// stored version still equals the request's version; otherwise it fails
+// with 409 and the current workspace.
+func (h *WorkspaceHandlers) Workspace() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid, jobID, userID, ok := h.caller(w, r)
+		if !ok {
+			return
+		}
+		ws, state, ok := h.workspace(w, r, tid, jobID, userID)
+		if !ok {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			noStore(w)
+			api.JSON(w, http.StatusOK, workspace.Hydrate(r.Context(), h.ch, *ws, state))
+		case http.MethodPatch:
+			h.update(w, r, ws, state, userID)
+		case http.MethodDelete:
+			if ws.OwnerID != userID {
+				api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "only the owner can delete a workspace")
+				return
+			}
+			if err := h.pg.DeleteWorkspace(r.Context(), tid, jobID, ws.ID); err != nil {
+				if storage.IsNotFound(err) {
+					api.Error(w, http.StatusNotFound, api.ErrCodeNotFound, "workspace not found")
+					return
+				}
+				slog.Error("failed to delete workspace", "workspace_id", ws.ID, "error", err)
+				api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to delete workspace")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			api.Error(w, http.StatusMethodNotAllowed, api.ErrCodeInvalidRequest, "method not allowed")
+		}
+	})
+}
+
+func (h *WorkspaceHandlers) update(w http.ResponseWriter, r *http.Request, ws *domain.Workspace, state domain.WorkspaceState, userID string) {
+	owner := ws.OwnerID == userID
+	if !owner && ws.Sharing != domain.WorkspaceCollaborative {
+		api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "workspace is shared read-only")
+		return
+	}
+	req, ok := decodeWorkspaceRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Version == nil {
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "version is required")
+		return
+	}
+	if req.Sharing != nil && *req.Sharing != ws.Sharing && !owner {
+		api.Error(w, http.StatusForbidden, api.ErrCodeForbidden, "only the owner can change how a workspace is shared")
+		return
+	}
+	if ws.Version != *req.Version {
+		workspaceConflict(w, ws)
+		return
+	}
+
+	updated := *ws
+	updated.UpdatedBy = userID
+	newState, ok := applyWorkspaceRequest(w, &updated, state, req)
+	if !ok {
+		return
+	}
+	if err := h.pg.UpdateWorkspace(r.Context(), &updated, *req.Version); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			if current, err := h.pg.GetWorkspace(r.Context(), ws.TenantID, ws.JobID, ws.ID); err == nil {
+				if _, err := workspace.Load(current); err == nil {
+					ws = current
+				}
+			}
+			workspaceConflict(w, ws)
+			return
+		}
+		slog.Error("failed to update workspace", "workspace_id", ws.ID, "error", err)
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to update workspace")
+		return
+	}
+
+	api.JSON(w, http.StatusOK, workspace.Hydrate(r.Context(), h.ch, updated, newState))
+}
+
+// decodeWorkspaceRequest reads the body of a workspace create or update.
+func decodeWorkspaceRequest(w http.ResponseWriter, r *http.Request) (*workspaceRequest, bool) {
+	var req workspaceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*workspace.MaxStateBytes)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			api.Error(w, http.StatusRequestEntityTooLarge, api.ErrCodeFileTooLarge,
+				fmt.Sprintf("workspace state exceeds %d bytes", workspace.MaxStateBytes))
+			return nil, false
+		}
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid JSON body")
+		return nil, false
+	}
+	return &req, true
+}
+
+// applyWorkspaceRequest applies the fields of req to ws over its current
+// state, writing the error response when they are invalid. The state is
+// validated against its schema version and stored at the current one.
+func applyWorkspaceRequest(w http.ResponseWriter, ws *domain.Workspace, state domain.WorkspaceState, req *workspaceRequest) (domain.WorkspaceState, bool) {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > workspace.MaxNameLength {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest,
+				fmt.Sprintf("name must be 1 to %d characters", workspace.MaxNameLength))
+			return state, false
+		}
+		ws.Name = name
+	}
+	if req.Sharing != nil {
+		switch *req.Sharing {
+		case domain.WorkspacePrivate, domain.WorkspaceReadOnly, domain.WorkspaceCollaborative:
+			ws.Sharing = *req.Sharing
+		default:
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "sharing must be one of private, read_only, collaborative")
+			return state, false
+		}
+	}
+	if req.State != nil {
+		version := workspace.SchemaVersion
+		if req.SchemaVersion != nil {
+			version = *req.SchemaVersion
+		}
+		decoded, err := workspace.Decode(version, req.State)
+		if err != nil {
+			api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+			return state, false
+		}
+		state = decoded
+	}
+
+	if err := workspace.Validate(&state); err != nil {
+		var invalid *workspace.ValidationError
+		if errors.As(err, &invalid) {
+			api.ErrorWithDetails(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest, "workspace state is invalid", invalid.Problems)
+			return state, false
+		}
+		api.Error(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, err.Error())
+		return state, false
+	}
+	raw, err := workspace.Encode(state)
+	if err != nil {
+		var invalid *workspace.ValidationError
+		if errors.As(err, &invalid) {
+			api.ErrorWithDetails(w, http.StatusUnprocessableEntity, api.ErrCodeInvalidRequest, "workspace state is invalid", invalid.Problems)
+			return state, false
+		}
+		api.Error(w, http.StatusInternalServerError, api.ErrCodeInternalError, "failed to encode workspace state")
+		return state, false
+	}
+	ws.State, ws.SchemaVersion = raw, workspace.SchemaVersion
+	return state, true
+}
+
+// workspaceConflict writes the 409 response of a stale update, with the
+// current workspace so the client can merge its changes.
+func workspaceConflict(w http.ResponseWriter, current *domain.Workspace) {
+	api.ErrorWithDetails(w, http.StatusConflict, api.ErrCodeConflict,
+		"workspace was changed by someone else; reload and retry", map[string]any{
+			"current_version": current.Version,
+			"current":         current,
+		})
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var fixedWorkspaceID = uuid.MustParse("00000000-0000-0000-0000-0000000000f1")
+
+// storedWorkspace is a workspace of fixedJobID owned by owner, at version 3.
+func storedWorkspace(owner string, sharing domain.WorkspaceSharing) *domain.Workspace {
+	return &domain.Workspace{
+		ID: fixedWorkspaceID, TenantID: fixedTenantID, JobID: fixedJobID,
+		Name: "war room", OwnerID: owner, Sharing: sharing,
+		SchemaVersion: 1, Version: 3,
+		State: json.RawMessage(`{"pinned_entries":["e1"],"queries":[{"query":"type:API"}],"forms":[],"queues":[],"notes":""}`),
+	}
+}
+
+// serveWorkspace serves one request of a single workspace as test-user.
+func serveWorkspace(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1/analyses/"+fixedJobID.String()+"/workspaces/"+fixedWorkspaceID.String(), bytes.NewBufferString(body))
+	req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{
+		"job_id": fixedJobID.String(), "workspace_id": fixedWorkspaceID.String(),
+	})
+	w := httptest.NewRecorder()
+	NewWorkspaceHandlers(pg, ch).Workspace().ServeHTTP(w, req)
+	return w
+}
+
+func TestWorkspaceHandlers_Create(t *testing.T) {
+	job := &domain.AnalysisJob{ID: fixedJobID, TenantID: fixedTenantID, Status: domain.JobStatusComplete}
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "creates a hydrated private workspace",
+			body: `{"name":" war room ","state":{"pinned_entries":["e1","e1"],"queries":[{"query":"type:API"}],"notes":"since 09:12"}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("CreateWorkspace", mock.Anything, mock.MatchedBy(func(ws *domain.Workspace) bool {
+					return ws.Name == "war room" && ws.OwnerID == "test-user" && ws.Sharing == domain.WorkspacePrivate &&
+						ws.SchemaVersion == 1 && ws.JobID == fixedJobID
+				})).Run(func(args mock.Arguments) { args.Get(1).(*domain.Workspace).Version = 1 }).Return(nil)
+				ch.On("GetEntrySummaries", mock.Anything, fixedTenantID.String(), fixedJobID.String(), []string{"e1"}).
+					Return([]domain.LogEntry{{EntryID: "e1", LogType: domain.LogTypeAPI, RawText: "+GE"}}, nil)
+			},
+			wantStatus: http.StatusCreated,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var view domain.WorkspaceView
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&view))
+				assert.Equal(t, 1, view.Version)
+				require.Len(t, view.Pins, 1)
+				assert.True(t, view.Pins[0].Found)
+				assert.JSONEq(t, `{"pinned_entries":["e1"],"queries":[{"query":"type:API"}],"forms":[],"queues":[],"notes":"since 09:12"}`, string(view.State))
+				require.Len(t, view.Queries, 1)
+				assert.True(t, view.Queries[0].Parses)
+			},
+		},
+		{
+			name:       "name is required",
+			body:       `{"state":{}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown state field is rejected",
+			body:       `{"name":"w","state":{"pins":["e1"]}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, decodeError(t, w).Message, `unknown field "pins"`)
+			},
+		},
+		{
+			name:       "unsupported schema version is rejected",
+			body:       `{"name":"w","schema_version":9,"state":{}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid state lists its problems",
+			body:       `{"name":"w","state":{"queries":[{"name":"empty"}],"time_window":{"from":"yesterday","to":"capture_end"}}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusUnprocessableEntity,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				resp := decodeError(t, w)
+				problems, _ := json.Marshal(resp.Details)
+				assert.Contains(t, string(problems), `"path":"queries[0].query"`)
+				assert.Contains(t, string(problems), `"path":"time_window.from"`)
+			},
+		},
+		{
+			name:       "invalid sharing is rejected",
+			body:       `{"name":"w","sharing":"public"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(job, nil)
+			pg.On("ListWorkspaces", mock.Anything, fixedTenantID, fixedJobID, "test-user").Return([]domain.Workspace{}, nil)
+			tc.setupMocks(pg, ch)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analyses/"+fixedJobID.String()+"/workspaces", bytes.NewBufferString(tc.body))
+			req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+			w := httptest.NewRecorder()
+			NewWorkspaceHandlers(pg, ch).Workspaces().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWorkspaceHandlers_List(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetJob", mock.Anything, fixedTenantID, fixedJobID).Return(&domain.AnalysisJob{ID: fixedJobID}, nil)
+	pg.On("ListWorkspaces", mock.Anything, fixedTenantID, fixedJobID, "test-user").
+		Return([]domain.Workspace{*storedWorkspace("test-user", domain.WorkspacePrivate), *storedWorkspace("other", domain.WorkspaceReadOnly)}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/analyses/"+fixedJobID.String()+"/workspaces", nil)
+	req = mux.SetURLVars(injectAuth(req, fixedTenantID.String()), map[string]string{"job_id": fixedJobID.String()})
+	w := httptest.NewRecorder()
+	NewWorkspaceHandlers(pg, new(testutil.MockClickHouseStore)).Workspaces().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Workspaces []domain.Workspace `json:"workspaces"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Len(t, resp.Workspaces, 2)
+}
+
+func TestWorkspaceHandlers_Get(t *testing.T) {
+	t.Run("hydrates pins no longer held", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		ch := new(testutil.MockClickHouseStore)
+		pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(storedWorkspace("other", domain.WorkspaceReadOnly), nil)
+		ch.On("GetEntrySummaries", mock.Anything, fixedTenantID.String(), fixedJobID.String(), []string{"e1"}).Return([]domain.LogEntry{}, nil)
+
+		w := serveWorkspace(pg, ch, http.MethodGet, "")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var view domain.WorkspaceView
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&view))
+		require.Len(t, view.Pins, 1)
+		assert.Equal(t, domain.WorkspacePin{EntryID: "e1"}, view.Pins[0])
+	})
+
+	t.Run("private workspace of another user is not found", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(storedWorkspace("other", domain.WorkspacePrivate), nil)
+
+		w := serveWorkspace(pg, new(testutil.MockClickHouseStore), http.MethodGet, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("unknown workspace", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(nil, fmt.Errorf("postgres: workspace not found: %s", fixedWorkspaceID))
+
+		w := serveWorkspace(pg, new(testutil.MockClickHouseStore), http.MethodGet, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestWorkspaceHandlers_Update(t *testing.T) {
+	tests := []struct {
+		name       string
+		owner      string
+		sharing    domain.WorkspaceSharing
+		body       string
+		setupMocks func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore)
+		wantStatus int
+		checkBody  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "collaborator updates the state",
+			owner:   "other",
+			sharing: domain.WorkspaceCollaborative,
+			body:    `{"version":3,"state":{"pinned_entries":["e1","e2"],"notes":"handed over"}}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("UpdateWorkspace", mock.Anything, mock.MatchedBy(func(ws *domain.Workspace) bool {
+					return ws.UpdatedBy == "test-user" && ws.Name == "war room" && ws.Sharing == domain.WorkspaceCollaborative
+				}), 3).Run(func(args mock.Arguments) { args.Get(1).(*domain.Workspace).Version = 4 }).Return(nil)
+				ch.On("GetEntrySummaries", mock.Anything, fixedTenantID.String(), fixedJobID.String(), []string{"e1", "e2"}).Return([]domain.LogEntry{}, nil)
+			},
+			wantStatus: http.StatusOK,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var view domain.WorkspaceView
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&view))
+				assert.Equal(t, 4, view.Version)
+				assert.Len(t, view.Pins, 2)
+				assert.Empty(t, view.Queries, "state is replaced as a whole")
+			},
+		},
+		{
+			name:    "owner renames keeping the state",
+			owner:   "test-user",
+			sharing: domain.WorkspacePrivate,
+			body:    `{"version":3,"name":"night shift","sharing":"read_only"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("UpdateWorkspace", mock.Anything, mock.MatchedBy(func(ws *domain.Workspace) bool {
+					var state domain.WorkspaceState
+					return json.Unmarshal(ws.State, &state) == nil && len(state.PinnedEntries) == 1 &&
+						ws.Name == "night shift" && ws.Sharing == domain.WorkspaceReadOnly
+				}), 3).Return(nil)
+				ch.On("GetEntrySummaries", mock.Anything, mock.Anything, mock.Anything, []string{"e1"}).Return([]domain.LogEntry{}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "stale version conflicts",
+			owner:      "test-user",
+			sharing:    domain.WorkspacePrivate,
+			body:       `{"version":2,"name":"late"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusConflict,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				resp := decodeError(t, w)
+				assert.Equal(t, api.ErrCodeConflict, resp.Code)
+				details := resp.Details.(map[string]any)
+				assert.Equal(t, float64(3), details["current_version"])
+				assert.Equal(t, "war room", details["current"].(map[string]any)["name"])
+			},
+		},
+		{
+			name:    "concurrent update conflicts with the newer workspace",
+			owner:   "test-user",
+			sharing: domain.WorkspacePrivate,
+			body:    `{"version":3,"name":"mine"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("UpdateWorkspace", mock.Anything, mock.Anything, 3).Return(storage.ErrVersionConflict)
+				newer := storedWorkspace("test-user", domain.WorkspacePrivate)
+				newer.Version, newer.Name = 4, "theirs"
+				pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(newer, nil).Once()
+			},
+			wantStatus: http.StatusConflict,
+			checkBody: func(t *testing.T, w *httptest.ResponseRecorder) {
+				details := decodeError(t, w).Details.(map[string]any)
+				assert.Equal(t, float64(4), details["current_version"])
+				assert.Equal(t, "theirs", details["current"].(map[string]any)["name"])
+			},
+		},
+		{
+			name:       "version is required",
+			owner:      "test-user",
+			sharing:    domain.WorkspacePrivate,
+			body:       `{"name":"x"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "read-only workspace of another user",
+			owner:      "other",
+			sharing:    domain.WorkspaceReadOnly,
+			body:       `{"version":3,"name":"x"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "collaborator cannot change sharing",
+			owner:      "other",
+			sharing:    domain.WorkspaceCollaborative,
+			body:       `{"version":3,"sharing":"private"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "store failure",
+			owner:   "test-user",
+			sharing: domain.WorkspacePrivate,
+			body:    `{"version":3,"name":"x"}`,
+			setupMocks: func(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore) {
+				pg.On("UpdateWorkspace", mock.Anything, mock.Anything, 3).Return(errors.New("db down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pg := new(testutil.MockPostgresStore)
+			ch := new(testutil.MockClickHouseStore)
+			pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(storedWorkspace(tc.owner, tc.sharing), nil).Once()
+			tc.setupMocks(pg, ch)
+
+			w := serveWorkspace(pg, ch, http.MethodPatch, tc.body)
+
+			assert.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+			if tc.checkBody != nil {
+				tc.checkBody(t, w)
+			}
+			pg.AssertExpectations(t)
+			ch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWorkspaceHandlers_Delete(t *testing.T) {
+	t.Run("owner deletes", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(storedWorkspace("test-user", domain.WorkspacePrivate), nil)
+		pg.On("DeleteWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(nil)
+
+		w := serveWorkspace(pg, new(testutil.MockClickHouseStore), http.MethodDelete, "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("collaborator cannot delete", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetWorkspace", mock.Anything, fixedTenantID, fixedJobID, fixedWorkspaceID).Return(storedWorkspace("other", domain.WorkspaceCollaborative), nil)
+
+		w := serveWorkspace(pg, new(testutil.MockClickHouseStore), http.MethodDelete, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		pg.AssertNotCalled(t, "DeleteWorkspace", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// AdminMiddleware restricts routes to platform administrators, identified by
+// their user IDs. It must be placed after AuthMiddleware in the chain.
+type AdminMiddleware struct {
+	userIDs map[string]bool
+}
+
+// NewAdminMiddleware creates an AdminMiddleware admitting the given users.
+// With no users configured every request is refused.
+func NewAdminMiddleware(userIDs []string) *AdminMiddleware {
+	ids := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		if id != "" {
+			ids[id] = true
+		}
+	}
+	return &AdminMiddleware{userIDs: ids}
+}
+
+// IsAdmin reports whether userID belongs to a platform administrator.
+func (am *AdminMiddleware) IsAdmin(userID string) bool {
+	return am.userIDs[userID]
+}
+
+// RequireAdmin returns an http.Handler middleware that rejects requests from
+// users who are not administrators with 403 Forbidden.
+func (am *AdminMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserID(r.Context())
+		if !am.IsAdmin(userID) {
+			slog.Warn("admin route refused",
+				"path", r.URL.Path,
+				"user_id", userID,
+			)
+			writeError(w, http.StatusForbidden, errCodeForbidden, "administrator access required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminMiddleware_RequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		admins     []string
+		userID     string
+		wantStatus int
+	}{
+		{name: "listed user passes", admins: []string{"user-1", "user-2"}, userID: "user-2", wantStatus: http.StatusOK},
+		{name: "other user refused", admins: []string{"user-1"}, userID: "user-9", wantStatus: http.StatusForbidden},
+		{name: "no admins configured", admins: nil, userID: "user-1", wantStatus: http.StatusForbidden},
+		{name: "anonymous refused", admins: []string{""}, userID: "", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/tenants/retention", nil)
+			if tc.userID != "" {
+				req = req.WithContext(WithUserID(req.Context(), tc.userID))
+			}
+			w := httptest.NewRecorder()
+			NewAdminMiddleware(tc.admins).RequireAdmin(inner).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantStatus == http.StatusOK, called)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -24,13 +25,22 @@ const (
 	TenantIDKey contextKey = "tenant_id"
 	// OrgIDKey is the context key for the Clerk organization ID.
 	OrgIDKey contextKey = "org_id"
+	// OrgRoleKey is the context key for the user's role in the organization.
+	OrgRoleKey contextKey = "org_role"
 )
 
 // Error codes used within middleware responses.
 const (
-	errCodeUnauthorized = "unauthorized"
+	errCodeUnauthorized   = "unauthorized"
+	errCodeForbidden      = "forbidden"
+	errCodeRateLimited    = "rate_limited"
+	errCodeInvalidRequest = "invalid_request"
 )
 
+// devTokenTenantID is the tenant of dev bypass WebSocket connections, the
+// frontend's default development tenant.
+const devTokenTenantID = "00000000-0000-0000-0000-000000000001"
+
 // clockSkewSeconds is the tolerance in seconds applied to both the `exp`
 // and `nbf` JWT claims to account for clock drift between servers.
 const clockSkewSeconds = 30
@@ -53,6 +63,30 @@ func GetOrgID(ctx context.Context) string {
 	return v
 }
 
+// GetOrgRole extracts the user's Clerk organization role, such as
+// "org:admin", from the request context.
+func GetOrgRole(ctx context.Context) string {
+	v, _ := ctx.Value(OrgRoleKey).(string)
+	return v
+}
+
+// IsTenantAdmin reports whether the user administers the tenant of the
+// request: an organization admin, or the owner of a personal account.
+// Support engineers acting in a tenant never are.
+func IsTenantAdmin(ctx context.Context) bool {
+	if GetImpersonation(ctx) != nil || GetUserID(ctx) == "" {
+		return false
+	}
+	if GetOrgID(ctx) == "" {
+		return GetTenantID(ctx) == GetUserID(ctx)
+	}
+	switch GetOrgRole(ctx) {
+	case "org:admin", "admin":
+		return true
+	}
+	return false
+}
+
 // WithUserID returns a new context with the given user ID set.
 func WithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, UserIDKey, userID)
@@ -68,25 +102,56 @@ func WithOrgID(ctx context.Context, orgID string) context.Context {
 	return context.WithValue(ctx, OrgIDKey, orgID)
 }
 
-// AuthMiddleware validates JWT tokens from the Authorization header.
+// WithOrgRole returns a new context with the given org role set.
+func WithOrgRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, OrgRoleKey, role)
+}
+
+// Identity is who a request is authenticated as: the values the auth
+// middleware sets in the request context.
+type Identity struct {
+	UserID   string
+	TenantID string
+	OrgID    string
+	OrgRole  string
+}
+
+// AuthProvider verifies the bearer tokens of an identity provider.
+type AuthProvider interface {
+	Verify(token string) (*Identity, error)
+}
+
+// ErrMissingSubject is returned by providers for a valid token that names
+// no user.
+var ErrMissingSubject = errors.New("token missing subject claim")
+
+// AuthMiddleware validates bearer tokens from the Authorization header with
+// its provider.
 type AuthMiddleware struct {
-	clerkSecretKey string
-	devMode        bool
+	provider AuthProvider
+	devMode  bool
 }
 
-// NewAuthMiddleware creates a new AuthMiddleware.
+// NewAuthMiddleware creates an AuthMiddleware validating Clerk tokens.
 // When clerkSecretKey is empty and devMode is true, the middleware will accept
 // bypass headers instead of requiring a valid JWT.
 func NewAuthMiddleware(clerkSecretKey string, devMode bool) *AuthMiddleware {
+	return NewAuthMiddlewareWithProvider(NewClerkProvider(clerkSecretKey), devMode)
+}
+
+// NewAuthMiddlewareWithProvider creates an AuthMiddleware validating the
+// tokens of provider.
+func NewAuthMiddlewareWithProvider(provider AuthProvider, devMode bool) *AuthMiddleware {
 	return &AuthMiddleware{
-		clerkSecretKey: clerkSecretKey,
-		devMode:        devMode,
+		provider: provider,
+		devMode:  devMode,
 	}
 }
 
 // Authenticate returns an http.Handler middleware that validates JWT bearer
 // tokens. In development mode, the middleware also accepts X-Dev-User-ID and
-// X-Dev-Tenant-ID headers as a convenience bypass.
+// X-Dev-Tenant-ID headers as a convenience bypass; the dev user is a plain
+// organization member unless X-Dev-Org-Role says otherwise.
 func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// --- Development bypass -------------------------------------------
@@ -103,12 +168,17 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 				// check query parameters for the dev bypass token.
 				if devUser == "" && devTenant == "" && r.URL.Query().Get("token") == "dev" {
 					devUser = "dev-user"
-					devTenant = "dev-tenant"
+					devTenant = devTokenTenantID
 				}
 				if devUser != "" && devTenant != "" {
+					devRole := r.Header.Get("X-Dev-Org-Role")
+					if devRole == "" {
+						devRole = "org:member"
+					}
 					ctx := context.WithValue(r.Context(), UserIDKey, devUser)
 					ctx = context.WithValue(ctx, TenantIDKey, devTenant)
 					ctx = context.WithValue(ctx, OrgIDKey, devTenant)
+					ctx = context.WithValue(ctx, OrgRoleKey, devRole)
 					next.ServeHTTP(w, r.WithContext(ctx))
 					return
 				}
@@ -117,6 +187,12 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		// --- Extract bearer token ----------------------------------------
 		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" && (isWebSocketUpgrade(r) || isEventStream(r)) && r.URL.Query().Get("token") != "" {
+			// Browsers cannot set headers on WebSocket connections or
+			// EventSource streams, so they pass the token as a query
+			// parameter instead.
+			authHeader = "Bearer " + r.URL.Query().Get("token")
+		}
 		if authHeader == "" {
 			writeError(w, http.StatusUnauthorized, errCodeUnauthorized, "missing authorization header")
 			return
@@ -129,9 +205,13 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 		}
 		token := parts[1]
 
-		// --- Decode and validate JWT -------------------------------------
-		claims, err := am.validateJWT(token)
+		// --- Verify token --------------------------------------------------
+		id, err := am.provider.Verify(token)
 		if err != nil {
+			if errors.Is(err, ErrMissingSubject) {
+				writeError(w, http.StatusUnauthorized, errCodeUnauthorized, ErrMissingSubject.Error())
+				return
+			}
 			slog.Warn("JWT validation failed",
 				"error", err,
 				"remote_addr", r.RemoteAddr,
@@ -140,28 +220,61 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		userID, _ := claims["sub"].(string)
-		if userID == "" {
-			writeError(w, http.StatusUnauthorized, errCodeUnauthorized, "token missing subject claim")
-			return
-		}
+		ctx := context.WithValue(r.Context(), UserIDKey, id.UserID)
+		ctx = context.WithValue(ctx, TenantIDKey, id.TenantID)
+		ctx = context.WithValue(ctx, OrgIDKey, id.OrgID)
+		ctx = context.WithValue(ctx, OrgRoleKey, id.OrgRole)
 
-		// Clerk stores the org ID in the "org_id" claim.
-		orgID, _ := claims["org_id"].(string)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		// Use org_id as the tenant identifier; fall back to user_id for
-		// personal accounts that have no organization.
-		tenantID := orgID
-		if tenantID == "" {
-			tenantID = userID
-		}
+// isWebSocketUpgrade reports whether r opens a WebSocket connection.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
 
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
-		ctx = context.WithValue(ctx, TenantIDKey, tenantID)
-		ctx = context.WithValue(ctx, OrgIDKey, orgID)
+// isEventStream reports whether r opens a server-sent event stream, as an
+// EventSource does.
+func isEventStream(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// ClerkProvider verifies Clerk session tokens.
+type ClerkProvider struct {
+	secretKey string
+}
+
+// NewClerkProvider creates a ClerkProvider for the Clerk secret key.
+func NewClerkProvider(secretKey string) *ClerkProvider {
+	return &ClerkProvider{secretKey: secretKey}
+}
+
+// Verify returns the identity of a Clerk token. The tenant is the
+// organization, or the user for personal accounts.
+func (p *ClerkProvider) Verify(token string) (*Identity, error) {
+	claims, err := p.validateJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := claims["sub"].(string)
+	if userID == "" {
+		return nil, ErrMissingSubject
+	}
+
+	// Clerk stores the org ID in the "org_id" claim and the user's role
+	// in it in "org_role".
+	orgID, _ := claims["org_id"].(string)
+	orgRole, _ := claims["org_role"].(string)
+
+	// Use org_id as the tenant identifier; fall back to user_id for
+	// personal accounts that have no organization.
+	tenantID := orgID
+	if tenantID == "" {
+		tenantID = userID
+	}
+	return &Identity{UserID: userID, TenantID: tenantID, OrgID: orgID, OrgRole: orgRole}, nil
 }
 
 // clerkJWTClaims is a minimal representation of the JWT payload.
@@ -171,7 +284,7 @@ type clerkJWTClaims map[string]interface{}
 // against the Clerk secret key. For production use, consider migrating to a
 // full JWKS-based verification flow with RS256, but HS256 with the Clerk
 // secret is the documented simple path for server-side validation.
-func (am *AuthMiddleware) validateJWT(tokenStr string) (clerkJWTClaims, error) {
+func (p *ClerkProvider) validateJWT(tokenStr string) (clerkJWTClaims, error) {
 	parts := strings.Split(tokenStr, ".")
 	if len(parts) != 3 {
 		return nil, fmt.Errorf("malformed JWT: expected 3 parts, got %d", len(parts))
@@ -197,7 +310,7 @@ func (am *AuthMiddleware) validateJWT(tokenStr string) (clerkJWTClaims, error) {
 
 	// --- Verify HMAC-SHA256 signature ------------------------------------
 	signingInput := headerB64 + "." + payloadB64
-	mac := hmac.New(sha256.New, []byte(am.clerkSecretKey))
+	mac := hmac.New(sha256.New, []byte(p.secretKey))
 	mac.Write([]byte(signingInput))
 	expectedSig := mac.Sum(nil)
 
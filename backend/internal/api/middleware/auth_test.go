@@ -36,6 +36,7 @@ func echoHandler() http.Handler {
 		w.Header().Set("X-User-ID", GetUserID(r.Context()))
 		w.Header().Set("X-Tenant-ID", GetTenantID(r.Context()))
 		w.Header().Set("X-Org-ID", GetOrgID(r.Context()))
+		w.Header().Set("X-Org-Role", GetOrgRole(r.Context()))
 		w.WriteHeader(http.StatusOK)
 	})
 }
@@ -107,6 +108,21 @@ func TestAuthMiddleware_DevMode_ValidHeaders(t *testing.T) {
 	assert.Equal(t, "dev-user-1", w.Header().Get("X-User-ID"))
 	assert.Equal(t, "dev-tenant-1", w.Header().Get("X-Tenant-ID"))
 	assert.Equal(t, "dev-tenant-1", w.Header().Get("X-Org-ID"))
+	assert.Equal(t, "org:member", w.Header().Get("X-Org-Role"), "a dev user is no admin unless asked")
+}
+
+func TestAuthMiddleware_DevMode_OrgRoleHeader(t *testing.T) {
+	handler := NewAuthMiddleware(testSecret, true).Authenticate(echoHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Dev-User-ID", "dev-user-1")
+	req.Header.Set("X-Dev-Tenant-ID", "dev-tenant-1")
+	req.Header.Set("X-Dev-Org-Role", "org:admin")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "org:admin", w.Header().Get("X-Org-Role"))
 }
 
 func TestAuthMiddleware_DevMode_MissingHeaders_NoBearer(t *testing.T) {
@@ -231,6 +247,39 @@ func TestAuthMiddleware_ValidJWT_WithOrg(t *testing.T) {
 	assert.Equal(t, "org_xyz789", w.Header().Get("X-Org-ID"))
 }
 
+func TestAuthMiddleware_ValidJWT_OrgRole(t *testing.T) {
+	handler := NewAuthMiddleware(testSecret, false).Authenticate(echoHandler())
+
+	token := createTestJWT(testSecret, map[string]interface{}{
+		"sub":      "user_abc123",
+		"org_id":   "org_xyz789",
+		"org_role": "org:admin",
+		"exp":      float64(time.Now().Add(1 * time.Hour).Unix()),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "org:admin", w.Header().Get("X-Org-Role"))
+}
+
+func TestIsTenantAdmin(t *testing.T) {
+	member := WithOrgID(WithTenantID(WithUserID(context.Background(), "user-1"), "tenant-1"), "org-1")
+	assert.False(t, IsTenantAdmin(member))
+	assert.False(t, IsTenantAdmin(WithOrgRole(member, "org:member")))
+	assert.True(t, IsTenantAdmin(WithOrgRole(member, "org:admin")))
+
+	personal := WithTenantID(WithUserID(context.Background(), "user-1"), "user-1")
+	assert.True(t, IsTenantAdmin(personal), "owners of personal accounts administer them")
+
+	impersonating := WithImpersonation(WithOrgRole(member, "org:admin"), &Impersonation{ActorTenantID: "tenant-9"})
+	assert.False(t, IsTenantAdmin(impersonating), "support engineers never administer the tenant they act in")
+
+	assert.False(t, IsTenantAdmin(context.Background()))
+}
+
 func TestAuthMiddleware_ValidJWT_NoOrg_FallsBackToUserID(t *testing.T) {
 	am := NewAuthMiddleware(testSecret, false)
 	handler := am.Authenticate(echoHandler())
@@ -560,12 +609,12 @@ func TestAuthMiddleware_UnsupportedAlgorithm(t *testing.T) {
 func TestNewAuthMiddleware(t *testing.T) {
 	am := NewAuthMiddleware("my-secret", true)
 	require.NotNil(t, am)
-	assert.Equal(t, "my-secret", am.clerkSecretKey)
+	assert.Equal(t, "my-secret", am.provider.(*ClerkProvider).secretKey)
 	assert.True(t, am.devMode)
 
 	am2 := NewAuthMiddleware("", false)
 	require.NotNil(t, am2)
-	assert.Equal(t, "", am2.clerkSecretKey)
+	assert.Equal(t, "", am2.provider.(*ClerkProvider).secretKey)
 	assert.False(t, am2.devMode)
 }
 
@@ -583,3 +632,129 @@ func TestAuthMiddleware_ErrorResponse_IsJSON(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, w.Code)
 	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
 }
+
+// --- Custom provider tests --------------------------------------------------
+
+// stubProvider accepts one token as a fixed identity.
+type stubProvider struct {
+	token string
+	id    *Identity
+	err   error
+}
+
+func (p *stubProvider) Verify(token string) (*Identity, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if token != p.token {
+		return nil, fmt.Errorf("unknown token")
+	}
+	return p.id, nil
+}
+
+func TestAuthMiddleware_Provider_SetsIdentity(t *testing.T) {
+	p := &stubProvider{token: "local-token", id: &Identity{UserID: "u1", TenantID: "t1", OrgID: "t1", OrgRole: "org:admin"}}
+	handler := NewAuthMiddlewareWithProvider(p, false).Authenticate(echoHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer local-token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "u1", w.Header().Get("X-User-ID"))
+	assert.Equal(t, "t1", w.Header().Get("X-Tenant-ID"))
+	assert.Equal(t, "t1", w.Header().Get("X-Org-ID"))
+	assert.Equal(t, "org:admin", w.Header().Get("X-Org-Role"))
+}
+
+func TestAuthMiddleware_Provider_RejectsClerkToken(t *testing.T) {
+	p := &stubProvider{token: "local-token", id: &Identity{UserID: "u1", TenantID: "t1"}}
+	handler := NewAuthMiddlewareWithProvider(p, false).Authenticate(echoHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+createTestJWT(testSecret, map[string]interface{}{"sub": "user_1"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthMiddleware_Provider_MissingSubject(t *testing.T) {
+	handler := NewAuthMiddlewareWithProvider(&stubProvider{err: ErrMissingSubject}, false).Authenticate(echoHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "token missing subject claim")
+}
+
+// --- WebSocket token tests --------------------------------------------------
+
+func TestAuthMiddleware_WebSocket_QueryToken(t *testing.T) {
+	tests := []struct {
+		name string
+		am   *AuthMiddleware
+		tok  string
+	}{
+		{"clerk", NewAuthMiddleware(testSecret, false), createTestJWT(testSecret, map[string]interface{}{
+			"sub": "user_ws", "org_id": "org_ws", "exp": float64(time.Now().Add(time.Hour).Unix()),
+		})},
+		{"provider", NewAuthMiddlewareWithProvider(&stubProvider{
+			token: "local-token", id: &Identity{UserID: "user_ws", TenantID: "org_ws", OrgID: "org_ws"},
+		}, false), "local-token"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := tc.am.Authenticate(echoHandler())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/ws?token="+tc.tok, nil)
+			req.Header.Set("Upgrade", "websocket")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "user_ws", w.Header().Get("X-User-ID"))
+			assert.Equal(t, "org_ws", w.Header().Get("X-Tenant-ID"))
+		})
+	}
+}
+
+func TestAuthMiddleware_QueryToken_IgnoredWithoutUpgrade(t *testing.T) {
+	am := NewAuthMiddlewareWithProvider(&stubProvider{token: "local-token", id: &Identity{UserID: "u1", TenantID: "t1"}}, false)
+	handler := am.Authenticate(echoHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/test?token=local-token", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthMiddleware_QueryToken_EventStream(t *testing.T) {
+	am := NewAuthMiddleware(testSecret, false)
+	handler := am.Authenticate(echoHandler())
+	token := createTestJWT(testSecret, map[string]interface{}{
+		"sub":    "user_abc123",
+		"org_id": "org_xyz789",
+		"exp":    float64(time.Now().Add(1 * time.Hour).Unix()),
+	})
+
+	// EventSource cannot set headers, so event streams may pass the token
+	// in the query string as WebSocket upgrades do.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream/sse?token="+token, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "org_xyz789", w.Header().Get("X-Tenant-ID"))
+
+	// Other requests may not.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/stream/sse?token="+token, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+)
+
+// byteBucket is a token bucket of bytes refilled at rate bytes per second up
+// to burst. Reads reserve their bytes after the fact and may drive the
+// bucket into debt, which the next reads wait out; that keeps the sustained
+// rate exact whatever the size of the reads.
+type byteBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newByteBucket(rate, burst int64, now time.Time) *byteBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &byteBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *byteBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes n bytes at now and returns how long to wait before they
+// may pass.
+func (b *byteBucket) reserve(n int, now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// available returns the bytes that may pass at now without waiting, which
+// is negative while the bucket is in debt.
+func (b *byteBucket) available(now time.Time) int64 {
+	b.refill(now)
+	return int64(b.tokens)
+}
+
+// tenantBandwidth is the bucket of a tenant and the uploads drawing on it.
+type tenantBandwidth struct {
+	bucket  *byteBucket
+	streams int
+}
+
+// BandwidthShaper throttles upload bodies to a rate per tenant beneath a
+// ceiling shared by every tenant. Its buckets are local to the replica, so
+// the global ceiling it is given is the replica's share of the deployment's.
+type BandwidthShaper struct {
+	tenantRate, tenantBurst int64
+	global                  *byteBucket
+
+	mu      sync.Mutex
+	tenants map[string]*tenantBandwidth
+	streams int
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewBandwidthShaper creates a shaper allowing each tenant tenantRate bytes
+// per second with bursts of tenantBurst, and every tenant together
+// globalRate with bursts of globalBurst. A rate of 0 is not limited; a
+// burst of 0 is one second of its rate. It returns nil, which shapes
+// nothing, when neither rate is set.
+func NewBandwidthShaper(tenantRate, tenantBurst, globalRate, globalBurst int64) *BandwidthShaper {
+	if tenantRate <= 0 && globalRate <= 0 {
+		return nil
+	}
+	s := &BandwidthShaper{
+		tenantRate:  max(tenantRate, 0),
+		tenantBurst: tenantBurst,
+		tenants:     make(map[string]*tenantBandwidth),
+	}
+	s.SetClock(clock.Real)
+	if s.tenantBurst <= 0 {
+		s.tenantBurst = s.tenantRate
+	}
+	if globalRate > 0 {
+		s.global = newByteBucket(globalRate, globalBurst, s.now())
+	}
+	return s
+}
+
+// SetClock makes the shaper refill its buckets, and wait for them, by c.
+// It must be called before the shaper is used.
+func (s *BandwidthShaper) SetClock(c clock.Clock) {
+	s.now = c.Now
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		return sleepContext(ctx, c, d)
+	}
+}
+
+func sleepContext(ctx context.Context, c clock.Clock, d time.Duration) error {
+	t := c.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
+
+// Wrap returns body throttled to the tenant's share of the bandwidth. Reads
+// stop waiting, and fail, once ctx is done. The returned body must be
+// closed for the tenant's bucket to be released.
+func (s *BandwidthShaper) Wrap(ctx context.Context, tenantID string, body io.ReadCloser) io.ReadCloser {
+	if s == nil {
+		return body
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	tb := s.tenants[tenantID]
+	if tb == nil {
+		tb = &tenantBandwidth{}
+		if s.tenantRate > 0 {
+			tb.bucket = newByteBucket(s.tenantRate, s.tenantBurst, now)
+		}
+		s.tenants[tenantID] = tb
+	}
+	tb.streams++
+	s.streams++
+	return &shapedBody{ctx: ctx, body: body, shaper: s, tenant: tb, chunk: s.chunk()}
+}
+
+// sweep drops the buckets of tenants without uploads once they are full
+// again, as a fresh bucket would be. Buckets still in debt are kept so that
+// a tenant cannot escape its rate by starting a new upload.
+func (s *BandwidthShaper) sweep(now time.Time) {
+	for id, tb := range s.tenants {
+		if tb.streams == 0 && (tb.bucket == nil || tb.bucket.available(now) >= int64(tb.bucket.burst)) {
+			delete(s.tenants, id)
+		}
+	}
+}
+
+// chunk is the most a single read passes at once: the smallest burst, so
+// that one read does not run a bucket deep into debt.
+func (s *BandwidthShaper) chunk() int {
+	c := int64(0)
+	if s.tenantRate > 0 {
+		c = s.tenantBurst
+	}
+	if s.global != nil && (c == 0 || int64(s.global.burst) < c) {
+		c = int64(s.global.burst)
+	}
+	return int(max(c, 1))
+}
+
+// reserve takes n bytes from the tenant's and the global bucket and returns
+// how long to wait for both.
+func (s *BandwidthShaper) reserve(tb *tenantBandwidth, n int) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var wait time.Duration
+	if tb.bucket != nil {
+		wait = tb.bucket.reserve(n, now)
+	}
+	if s.global != nil {
+		wait = max(wait, s.global.reserve(n, now))
+	}
+	return wait
+}
+
+func (s *BandwidthShaper) release(tb *tenantBandwidth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tb.streams--
+	s.streams--
+}
+
+// BandwidthState is the state of a bucket of the shaper. Available is nil
+// when the bucket is not limited or not in use.
+type BandwidthState struct {
+	Rate      int64
+	Burst     int64
+	Available *int64
+	Streams   int
+}
+
+// TenantState returns the state of the tenant's bucket.
+func (s *BandwidthShaper) TenantState(tenantID string) BandwidthState {
+	if s == nil {
+		return BandwidthState{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := BandwidthState{Rate: s.tenantRate, Burst: s.tenantBurst}
+	if tb := s.tenants[tenantID]; tb != nil {
+		st.Streams = tb.streams
+		if tb.bucket != nil {
+			avail := tb.bucket.available(s.now())
+			st.Available = &avail
+		}
+	}
+	return st
+}
+
+// GlobalState returns the state of the bucket shared by every tenant.
+func (s *BandwidthShaper) GlobalState() BandwidthState {
+	if s == nil {
+		return BandwidthState{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := BandwidthState{Streams: s.streams}
+	if s.global != nil {
+		avail := s.global.available(s.now())
+		st.Rate, st.Burst, st.Available = int64(s.global.rate), int64(s.global.burst), &avail
+	}
+	return st
+}
+
+// shapedBody is an upload body read no faster than its shaper allows.
+type shapedBody struct {
+	ctx    context.Context
+	body   io.ReadCloser
+	shaper *BandwidthShaper
+	tenant *tenantBandwidth
+	chunk  int
+	closed sync.Once
+}
+
+func (b *shapedBody) Read(p []byte) (int, error) {
+	if len(p) > b.chunk {
+		p = p[:b.chunk]
+	}
+	n, err := b.body.Read(p)
+	if n > 0 {
+		if wait := b.shaper.reserve(b.tenant, n); wait > 0 {
+			if serr := b.shaper.sleep(b.ctx, wait); serr != nil {
+				return n, serr
+			}
+		}
+	}
+	return n, err
+}
+
+func (b *shapedBody) Close() error {
+	b.closed.Do(func() { b.shaper.release(b.tenant) })
+	return b.body.Close()
+}
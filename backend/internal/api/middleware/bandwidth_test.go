@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is the clock of a shaper whose sleeps only move the clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newTestShaper(tenantRate, tenantBurst, globalRate, globalBurst int64) (*BandwidthShaper, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)}
+	s := NewBandwidthShaper(tenantRate, tenantBurst, globalRate, globalBurst)
+	s.now, s.sleep = clock.Now, clock.Sleep
+	if s.global != nil {
+		s.global.last = clock.now
+	}
+	return s, clock
+}
+
+func upload(size int) io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(make([]byte, size)))
+}
+
+func TestBandwidthShaper_SustainedThroughput(t *testing.T) {
+	const rate, burst, size = 1 << 20, 64 << 10, 20 << 20
+	s, clock := newTestShaper(rate, burst, 0, 0)
+	start := clock.Now()
+
+	body := s.Wrap(context.Background(), "t1", upload(size))
+	n, err := io.Copy(io.Discard, body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, int64(size), n)
+
+	// The burst passes at once, the rest at the rate.
+	want := time.Duration(float64(size-burst) / rate * float64(time.Second))
+	assert.InDelta(t, want.Seconds(), clock.Now().Sub(start).Seconds(), want.Seconds()*0.01)
+}
+
+func TestBandwidthShaper_ReadsNoMoreThanTheBurst(t *testing.T) {
+	s, _ := newTestShaper(1<<20, 4<<10, 0, 0)
+	body := s.Wrap(context.Background(), "t1", upload(1<<20))
+	n, err := body.Read(make([]byte, 1<<20))
+	require.NoError(t, err)
+	assert.Equal(t, 4<<10, n)
+}
+
+func TestBandwidthShaper_GlobalCeiling(t *testing.T) {
+	const rate, size = 1 << 20, 8 << 20
+	s, clock := newTestShaper(rate, 64<<10, rate, 64<<10)
+	start := clock.Now()
+
+	// Two tenants each allowed the whole ceiling share it.
+	a := s.Wrap(context.Background(), "a", upload(size))
+	b := s.Wrap(context.Background(), "b", upload(size))
+	assert.Equal(t, 2, s.GlobalState().Streams)
+	buf := make([]byte, 32<<10)
+	var total int
+	for {
+		na, erra := a.Read(buf)
+		nb, errb := b.Read(buf)
+		total += na + nb
+		if erra == io.EOF && errb == io.EOF {
+			break
+		}
+	}
+	require.Equal(t, 2*size, total)
+
+	elapsed := clock.Now().Sub(start).Seconds()
+	assert.InDelta(t, float64(2*size)/rate, elapsed, 0.1, "both uploads together get the global rate")
+
+	require.NoError(t, a.Close())
+	require.NoError(t, b.Close())
+	assert.Zero(t, s.GlobalState().Streams)
+}
+
+func TestBandwidthShaper_TenantsAreIndependent(t *testing.T) {
+	s, _ := newTestShaper(1<<20, 1<<20, 0, 0)
+	a := s.Wrap(context.Background(), "a", upload(1<<20))
+	_, err := io.Copy(io.Discard, a)
+	require.NoError(t, err)
+
+	st := s.TenantState("a")
+	require.NotNil(t, st.Available)
+	assert.Equal(t, int64(0), *st.Available, "a spent its burst")
+	assert.Equal(t, 1, st.Streams)
+	assert.Nil(t, s.TenantState("b").Available, "b has no upload")
+
+	// A bucket in debt outlives the upload; a full one is dropped.
+	require.NoError(t, a.Close())
+	require.NoError(t, s.Wrap(context.Background(), "b", upload(0)).Close())
+	require.NoError(t, s.Wrap(context.Background(), "c", upload(0)).Close())
+	assert.NotNil(t, s.TenantState("a").Available)
+	assert.Nil(t, s.TenantState("b").Available)
+}
+
+func TestBandwidthShaper_Cancelled(t *testing.T) {
+	s, _ := newTestShaper(1<<10, 1<<10, 0, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := s.Wrap(ctx, "t1", upload(4<<10))
+	_, err := io.Copy(io.Discard, body)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBandwidthShaper_Disabled(t *testing.T) {
+	s := NewBandwidthShaper(0, 0, 0, 0)
+	assert.Nil(t, s)
+	body := upload(10)
+	assert.Equal(t, body, s.Wrap(context.Background(), "t1", body))
+	assert.Equal(t, BandwidthState{}, s.GlobalState())
+}
@@ -43,9 +43,13 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 					"X-Requested-With",
 					"X-Dev-User-ID",
 					"X-Dev-Tenant-ID",
+					"X-Dev-Org-Role",
+					ActAsTenantHeader,
+					"If-None-Match",
+					IdempotencyKeyHeader,
 				}, ", "))
 				w.Header().Set("Access-Control-Max-Age", "86400")
-				w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+				w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, "+QueryIDHeader+", "+ActingAsTenantHeader+", "+IdempotentReplayHeader)
 			}
 
 			// Handle preflight requests.
@@ -35,7 +35,10 @@ func TestCORSMiddleware_Preflight_WildcardOrigin(t *testing.T) {
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "DELETE")
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
-	assert.Equal(t, "X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "If-None-Match")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), ActAsTenantHeader)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), IdempotencyKeyHeader)
+	assert.Equal(t, "X-Request-ID, ETag, X-Query-ID, X-Acting-As-Tenant, Idempotent-Replay", w.Header().Get("Access-Control-Expose-Headers"))
 }
 
 func TestCORSMiddleware_Preflight_SpecificOrigin(t *testing.T) {
@@ -9,19 +9,25 @@ import (
 // errorResponse mirrors the api.ErrorResponse structure but is defined here
 // to avoid an import cycle between the middleware and api packages.
 type errorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // writeError writes a JSON error response. This is a self-contained helper
 // so that middleware does not need to import the parent api package.
 func writeError(w http.ResponseWriter, status int, code string, message string) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(errorResponse{
+	writeJSON(w, status, errorResponse{
 		Code:    code,
 		Message: message,
-	}); err != nil {
-		slog.Error("failed to encode middleware error response", "error", err)
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode middleware response", "error", err)
 	}
 }
@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key of a mutating request.
+	// Retries of the request send the same key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on a response replayed from the stored
+	// response of an earlier request with the same key.
+	IdempotentReplayHeader = "Idempotent-Replay"
+)
+
+const (
+	// idempotencyLease bounds how long an in-flight request holds its key
+	// when the replica serving it dies before storing the response.
+	idempotencyLease = 5 * time.Minute
+	// idempotencyPoll is how often a duplicate of an in-flight request
+	// checks for its response.
+	idempotencyPoll = 100 * time.Millisecond
+	// maxIdempotencyKeyLen caps the length of a key.
+	maxIdempotencyKeyLen = 255
+	// maxIdempotentResponseBytes caps the response stored for a key. A
+	// longer response is not stored, and a retry runs the request again.
+	maxIdempotentResponseBytes = 1 << 20
+
+	errCodeConflict        = "conflict"
+	errCodeRequestInFlight = "request_in_flight"
+)
+
+// IdempotencyStore claims idempotency keys and stores the responses of the
+// requests that claimed them. It is implemented by storage.PostgresClient.
+type IdempotencyStore interface {
+	BeginIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, bool, error)
+	CompleteIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error
+	ReleaseIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error
+}
+
+// Idempotency makes the mutating routes it wraps safe to retry. The first
+// request sent with an Idempotency-Key header claims the key, per tenant
+// and route, and its response is stored for the key's TTL; later requests
+// with the key are answered with the stored response and the
+// Idempotent-Replay header without running again. A duplicate of a request
+// still in flight waits briefly for its response and is then answered 409
+// Conflict. Reusing a key for a different request is answered 422.
+// Responses with a 5xx status are not stored, so that the request can be
+// retried. Requests without the header are not affected, and when the
+// store cannot be reached requests run unchecked and are counted.
+type Idempotency struct {
+	store    IdempotencyStore
+	ttl      time.Duration
+	maxWait  time.Duration
+	failOpen atomic.Int64
+	clock    clock.Clock
+}
+
+// NewIdempotency creates an Idempotency keeping responses for ttl and
+// letting duplicates of an in-flight request wait at most maxWait.
+func NewIdempotency(store IdempotencyStore, ttl, maxWait time.Duration) *Idempotency {
+	return &Idempotency{store: store, ttl: ttl, maxWait: maxWait, clock: clock.Real}
+}
+
+// SetClock makes the middleware stamp claims, and wait for duplicates, by c.
+func (id *Idempotency) SetClock(c clock.Clock) {
+	id.clock = c
+}
+
+// FailOpenCount returns how many requests with a key ran unchecked because
+// the store failed.
+func (id *Idempotency) FailOpenCount() int64 {
+	return id.failOpen.Load()
+}
+
+// Idempotent returns an http.Handler that runs next at most once per
+// Idempotency-Key. It must be placed after AuthMiddleware in the chain.
+func (id *Idempotency) Idempotent(next http.Handler) http.Handler {
+	if id == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenantID, err := ParseID(GetTenantID(r.Context()))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest,
+				"Idempotency-Key must be 1 to 255 printable ASCII characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &domain.IdempotencyRecord{
+			TenantID:    tenantID,
+			Endpoint:    idempotencyEndpoint(r),
+			Key:         key,
+			RequestHash: idempotencyHash(r, body),
+		}
+		stored, ok := id.claim(w, r, rec)
+		switch {
+		case !ok:
+			return
+		case stored != nil:
+			replayResponse(w, stored)
+		default:
+			id.run(w, r, rec, next)
+		}
+	})
+}
+
+// claim claims the key of rec, waiting for an in-flight duplicate to
+// finish. It returns the stored record to replay, or nil when the request
+// is to run: the key was claimed, or the store failed. It returns false
+// when it has written the response itself.
+func (id *Idempotency) claim(w http.ResponseWriter, r *http.Request, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, bool) {
+	deadline := id.clock.Now().Add(id.maxWait)
+	for {
+		// Every attempt claims the key from its own time, so that a lapsed
+		// claim is taken over.
+		now := id.clock.Now().UTC()
+		rec.CreatedAt, rec.LockedUntil, rec.ExpiresAt = now, now.Add(idempotencyLease), now.Add(id.ttl)
+		stored, claimed, err := id.store.BeginIdempotentRequest(r.Context(), rec)
+		switch {
+		case err != nil:
+			id.failOpen.Add(1)
+			slog.Warn("idempotency store unavailable, running request",
+				"tenant_id", rec.TenantID,
+				"endpoint", rec.Endpoint,
+				"fail_open_total", id.failOpen.Load(),
+				"error", err,
+			)
+			return nil, true
+		case claimed:
+			return nil, true
+		case stored.RequestHash != rec.RequestHash:
+			writeError(w, http.StatusUnprocessableEntity, errCodeInvalidRequest,
+				"Idempotency-Key was already used for a different request")
+			return nil, false
+		case stored.StatusCode != 0:
+			return stored, true
+		}
+
+		if !id.clock.Now().Before(deadline) {
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, http.StatusConflict, errorResponse{
+				Code:    errCodeConflict,
+				Message: "a request with this Idempotency-Key is still in flight, retry later",
+				Details: map[string]interface{}{
+					"reason": errCodeRequestInFlight,
+				},
+			})
+			return nil, false
+		}
+		select {
+		case <-r.Context().Done():
+			return nil, false
+		case <-id.clock.After(idempotencyPoll):
+		}
+	}
+}
+
+// run runs next holding the key of rec and stores its response. The key
+// is released when the response is not stored, also when next panics.
+func (id *Idempotency) run(w http.ResponseWriter, r *http.Request, rec *domain.IdempotencyRecord, next http.Handler) {
+	rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	stored := false
+	defer func() {
+		if !stored {
+			id.release(r.Context(), rec)
+		}
+	}()
+	next.ServeHTTP(rr, r)
+
+	if rr.statusCode >= http.StatusInternalServerError || rr.overflow {
+		return
+	}
+	rec.StatusCode = rr.statusCode
+	rec.ContentType = rr.Header().Get("Content-Type")
+	rec.ResponseBody = rr.body.Bytes()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+	if err := id.store.CompleteIdempotentRequest(ctx, rec); err != nil {
+		slog.Warn("failed to store idempotent response", "tenant_id", rec.TenantID, "endpoint", rec.Endpoint, "error", err)
+		return
+	}
+	stored = true
+}
+
+// release gives up the key of rec, also when the request was cancelled.
+func (id *Idempotency) release(ctx context.Context, rec *domain.IdempotencyRecord) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := id.store.ReleaseIdempotentRequest(ctx, rec); err != nil {
+		slog.Warn("failed to release idempotency key", "tenant_id", rec.TenantID, "endpoint", rec.Endpoint, "error", err)
+	}
+}
+
+// replayResponse writes the response stored with rec.
+func replayResponse(w http.ResponseWriter, rec *domain.IdempotencyRecord) {
+	if rec.ContentType != "" {
+		w.Header().Set("Content-Type", rec.ContentType)
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.ResponseBody)
+}
+
+// validIdempotencyKey reports whether key is 1 to 255 printable ASCII
+// characters.
+func validIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyEndpoint scopes keys to the method and route of r, so that
+// one key can be used on different endpoints.
+func idempotencyEndpoint(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tpl
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// idempotencyHash hashes what makes a request: its path, which holds the
+// route's variables, its query and its body.
+func idempotencyHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder writes the response through to the client and keeps a
+// copy of it, up to maxIdempotentResponseBytes, to be stored.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if !rr.wroteHeader {
+		rr.statusCode, rr.wroteHeader = code, true
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	if !rr.overflow {
+		if rr.body.Len()+len(b) > maxIdempotentResponseBytes {
+			rr.overflow = true
+			rr.body.Reset()
+		} else {
+			rr.body.Write(b)
+		}
+	}
+	return rr.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher for streaming responses.
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
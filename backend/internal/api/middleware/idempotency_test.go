@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	idemTenant      = "00000000-0000-0000-0000-000000000001"
+	idemOtherTenant = "00000000-0000-0000-0000-000000000002"
+)
+
+// memoryIdempotencyStore keeps idempotency keys like the idempotency_keys
+// table of storage.PostgresClient.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]domain.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]domain.IdempotencyRecord{}}
+}
+
+func (s *memoryIdempotencyStore) id(rec *domain.IdempotencyRecord) string {
+	return rec.TenantID.String() + "|" + rec.Endpoint + "|" + rec.Key
+}
+
+func (s *memoryIdempotencyStore) BeginIdempotentRequest(_ context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.records[s.id(rec)]
+	if ok && existing.ExpiresAt.After(rec.CreatedAt) && (existing.StatusCode != 0 || existing.LockedUntil.After(rec.CreatedAt)) {
+		return &existing, false, nil
+	}
+	s.records[s.id(rec)] = *rec
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotentRequest(_ context.Context, rec *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.records[s.id(rec)]
+	if !ok || existing.StatusCode != 0 || !existing.CreatedAt.Equal(rec.CreatedAt) {
+		return errors.New("idempotency key claim not found")
+	}
+	s.records[s.id(rec)] = *rec
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotentRequest(_ context.Context, rec *domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[s.id(rec)]; ok && existing.StatusCode == 0 && existing.CreatedAt.Equal(rec.CreatedAt) {
+		delete(s.records, s.id(rec))
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// createHandler creates a job per call, taking delay to do so, and
+// answers with the number of the job.
+func createHandler(calls *atomic.Int64, delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		time.Sleep(delay)
+		writeJSON(w, http.StatusCreated, map[string]int64{"job": n})
+	})
+}
+
+func idempotentRequest(tenantID, path, key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req.WithContext(WithTenantID(req.Context(), tenantID))
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	var calls atomic.Int64
+	h := NewIdempotency(newMemoryIdempotencyStore(), time.Hour, time.Second).Idempotent(createHandler(&calls, 0))
+
+	first := serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", `{"file_id":"f"}`))
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayHeader))
+
+	replay := serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", `{"file_id":"f"}`))
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, "application/json; charset=utf-8", replay.Header().Get("Content-Type"))
+	assert.JSONEq(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, int64(1), calls.Load())
+
+	// Keys are scoped per tenant, and requests without a key always run.
+	assert.Empty(t, serve(h, idempotentRequest(idemOtherTenant, "/api/v1/analysis", "k1", `{"file_id":"f"}`)).Header().Get(IdempotentReplayHeader))
+	serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "", `{"file_id":"f"}`))
+	assert.Equal(t, int64(3), calls.Load())
+}
+
+func TestIdempotency_DifferentRequestSameKey(t *testing.T) {
+	var calls atomic.Int64
+	h := NewIdempotency(newMemoryIdempotencyStore(), time.Hour, time.Second).Idempotent(createHandler(&calls, 0))
+
+	require.Equal(t, http.StatusCreated, serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", `{"file_id":"a"}`)).Code)
+
+	for _, path := range []string{"/api/v1/analysis", "/api/v1/analysis?priority=batch"} {
+		rr := serve(h, idempotentRequest(idemTenant, path, "k1", `{"file_id":"b"}`))
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, path)
+	}
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestIdempotency_ConcurrentDuplicatesWait(t *testing.T) {
+	var calls atomic.Int64
+	h := NewIdempotency(newMemoryIdempotencyStore(), time.Hour, 5*time.Second).Idempotent(createHandler(&calls, 200*time.Millisecond))
+
+	const n = 8
+	responses := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", `{"file_id":"f"}`))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), calls.Load(), "one job for all duplicates")
+	replays := 0
+	for _, rr := range responses {
+		require.Equal(t, http.StatusCreated, rr.Code)
+		assert.JSONEq(t, `{"job":1}`, rr.Body.String())
+		if rr.Header().Get(IdempotentReplayHeader) == "true" {
+			replays++
+		}
+	}
+	assert.Equal(t, n-1, replays)
+}
+
+func TestIdempotency_ConcurrentDuplicatesConflict(t *testing.T) {
+	var calls atomic.Int64
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	})
+	fake := clock.NewFake(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC))
+	idem := NewIdempotency(newMemoryIdempotencyStore(), time.Hour, idempotencyPoll)
+	idem.SetClock(fake)
+	h := idem.Idempotent(slow)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(h, idempotentRequest(idemTenant, "/api/v1/analysis/j/report", "k1", "")) }()
+	<-started
+
+	const n = 5
+	var wg sync.WaitGroup
+	var conflicts atomic.Int64
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := serve(h, idempotentRequest(idemTenant, "/api/v1/analysis/j/report", "k1", ""))
+			if rr.Code != http.StatusConflict {
+				return
+			}
+			var body errorResponse
+			if assert.NoError(t, json.NewDecoder(rr.Body).Decode(&body)) {
+				assert.Equal(t, map[string]interface{}{"reason": errCodeRequestInFlight}, body.Details)
+			}
+			assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+			conflicts.Add(1)
+		}()
+	}
+	// Every duplicate polls once, then gives up at the deadline.
+	fake.BlockUntil(n)
+	fake.Advance(idempotencyPoll)
+	wg.Wait()
+	assert.Equal(t, int64(n), conflicts.Load())
+
+	close(release)
+	assert.Equal(t, http.StatusAccepted, (<-done).Code)
+	replay := serve(h, idempotentRequest(idemTenant, "/api/v1/analysis/j/report", "k1", ""))
+	assert.Equal(t, http.StatusAccepted, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestIdempotency_LapsedClaimIsTakenOver(t *testing.T) {
+	// The replica serving the first request died holding the key: once its
+	// lease lapses a retry claims the key and runs.
+	store := newMemoryIdempotencyStore()
+	fake := clock.NewFake(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC))
+	idem := NewIdempotency(store, time.Hour, 0)
+	idem.SetClock(fake)
+	var calls atomic.Int64
+	h := idem.Idempotent(createHandler(&calls, 0))
+
+	req := idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}")
+	rec := &domain.IdempotencyRecord{
+		TenantID:    uuid.MustParse(idemTenant),
+		Endpoint:    idempotencyEndpoint(req),
+		Key:         "k1",
+		RequestHash: idempotencyHash(req, []byte("{}")),
+		CreatedAt:   fake.Now(),
+		LockedUntil: fake.Now().Add(idempotencyLease),
+		ExpiresAt:   fake.Now().Add(time.Hour),
+	}
+	_, claimed, err := store.BeginIdempotentRequest(context.Background(), rec)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	assert.Equal(t, http.StatusConflict, serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}")).Code, "the claim holds")
+	fake.Advance(idempotencyLease)
+	rr := serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}"))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, rr.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestIdempotency_ServerErrorsAreNotStored(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	var calls atomic.Int64
+	h := NewIdempotency(store, time.Hour, time.Second).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			writeError(w, http.StatusServiceUnavailable, "service_unavailable", "nats down")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}")).Code)
+	assert.Zero(t, store.len(), "the key is released")
+	rr := serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}"))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, rr.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func TestIdempotency_ReleasesKeyOnPanic(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	h := NewIdempotency(store, time.Hour, time.Second).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	assert.Panics(t, func() { serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}")) })
+	assert.Zero(t, store.len())
+}
+
+func TestIdempotency_InvalidKey(t *testing.T) {
+	var calls atomic.Int64
+	h := NewIdempotency(newMemoryIdempotencyStore(), time.Hour, time.Second).Idempotent(createHandler(&calls, 0))
+	for _, key := range []string{strings.Repeat("k", 256), "bad\tkey", "clé"} {
+		assert.Equal(t, http.StatusBadRequest, serve(h, idempotentRequest(idemTenant, "/api/v1/analysis", key, "{}")).Code, key)
+	}
+	assert.Zero(t, calls.Load())
+}
+
+type failingIdempotencyStore struct{}
+
+func (failingIdempotencyStore) BeginIdempotentRequest(context.Context, *domain.IdempotencyRecord) (*domain.IdempotencyRecord, bool, error) {
+	return nil, false, errors.New("postgres down")
+}
+
+func (failingIdempotencyStore) CompleteIdempotentRequest(context.Context, *domain.IdempotencyRecord) error {
+	return errors.New("postgres down")
+}
+
+func (failingIdempotencyStore) ReleaseIdempotentRequest(context.Context, *domain.IdempotencyRecord) error {
+	return errors.New("postgres down")
+}
+
+func TestIdempotency_FailOpen(t *testing.T) {
+	var calls atomic.Int64
+	idem := NewIdempotency(failingIdempotencyStore{}, time.Hour, time.Second)
+	for i := 0; i < 3; i++ {
+		rr := serve(idem.Idempotent(createHandler(&calls, 0)), idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}"))
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.JSONEq(t, fmt.Sprintf(`{"job":%d}`, i+1), rr.Body.String())
+	}
+	assert.Equal(t, int64(3), idem.FailOpenCount())
+
+	var nilIdem *Idempotency
+	assert.Equal(t, http.StatusOK, serve(nilIdem.Idempotent(okHandler()), idempotentRequest(idemTenant, "/api/v1/analysis", "k1", "{}")).Code)
+}
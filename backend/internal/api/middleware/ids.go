@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// idsKey is the context key of the canonical identifiers of a request.
+const idsKey contextKey = "ids"
+
+// Errors returned by ParseID.
+var (
+	ErrMalformedID = errors.New("must be a UUID in the form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx")
+	ErrNilID       = errors.New("must not be the nil UUID")
+)
+
+// pathIDParams are the route variables that hold UUIDs.
+var pathIDParams = map[string]bool{
+	"tenant_id": true,
+	"job_id":    true,
+	"id":        true,
+	"export_id": true,
+	"grant_id":  true,
+	"group_id":  true,
+	"link_id":   true,
+	"rule_id":   true,
+	"search_id": true,
+	"query_id":  true,
+}
+
+// queryIDParams are the query parameters that hold UUIDs.
+var queryIDParams = []string{"job_id", "baseline", "candidate"}
+
+// ParseID parses an identifier in the hyphenated 36-character form, in
+// either case. Braced, URN and unhyphenated forms are rejected, as is the
+// nil UUID, which never identifies a record.
+func ParseID(raw string) (uuid.UUID, error) {
+	if len(raw) != 36 || strings.ContainsAny(raw, "{}:") {
+		return uuid.Nil, ErrMalformedID
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, ErrMalformedID
+	}
+	if id == uuid.Nil {
+		return uuid.Nil, ErrNilID
+	}
+	return id, nil
+}
+
+// IDError is the response to a request with an invalid identifier.
+type IDError struct {
+	Parameter string `json:"parameter"`
+	Source    string `json:"source"` // "path", "query", "body" or "auth"
+	Reason    string `json:"reason"`
+}
+
+// WriteIDError answers a request with 400, naming the invalid identifier.
+func WriteIDError(w http.ResponseWriter, parameter, source string, err error) {
+	writeJSON(w, http.StatusBadRequest, errorResponse{
+		Code:    errCodeInvalidRequest,
+		Message: "invalid " + parameter + " format: " + err.Error(),
+		Details: IDError{Parameter: parameter, Source: source, Reason: err.Error()},
+	})
+}
+
+// GetID returns the canonical UUID of the path or query parameter name, as
+// CanonicalIDs stored it. Path parameters take precedence.
+func GetID(ctx context.Context, name string) (uuid.UUID, bool) {
+	ids, _ := ctx.Value(idsKey).(map[string]uuid.UUID)
+	id, ok := ids[name]
+	return id, ok
+}
+
+// CanonicalIDs validates the tenant of the request and every UUID path and
+// query parameter, answering 400 naming the first invalid one. The
+// identifiers are stored in canonical lowercase form: the tenant in the
+// request context, and the parameters both for GetID and in the route
+// variables. It must be placed after the auth middleware and before
+// TenantMiddleware, which routes the request by its canonical tenant.
+func CanonicalIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if tenantID := GetTenantID(ctx); tenantID != "" {
+			tid, err := ParseID(tenantID)
+			if err != nil {
+				WriteIDError(w, "tenant_id", "auth", err)
+				return
+			}
+			ctx = WithTenantID(ctx, tid.String())
+		}
+
+		ids := make(map[string]uuid.UUID)
+		q := r.URL.Query()
+		for _, name := range queryIDParams {
+			raw := q.Get(name)
+			if raw == "" {
+				continue
+			}
+			id, err := ParseID(raw)
+			if err != nil {
+				WriteIDError(w, name, "query", err)
+				return
+			}
+			ids[name] = id
+		}
+		vars := mux.Vars(r)
+		for _, name := range slices.Sorted(maps.Keys(vars)) {
+			if !pathIDParams[name] {
+				continue
+			}
+			id, err := ParseID(vars[name])
+			if err != nil {
+				WriteIDError(w, name, "path", err)
+				return
+			}
+			ids[name] = id
+			vars[name] = id.String()
+		}
+
+		r = r.WithContext(context.WithValue(ctx, idsKey, ids))
+		if len(vars) > 0 {
+			r = mux.SetURLVars(r, vars)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	canonicalJobID = "0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d"
+	canonicalTenID = "6f1c2b3a-4d5e-4f60-8a9b-0c1d2e3f4a5b"
+)
+
+// malformedIDs are the forms of identifier that must be rejected, by class.
+var malformedIDs = map[string]struct {
+	raw string
+	err error
+}{
+	"braces":       {"{" + canonicalJobID + "}", ErrMalformedID},
+	"urn":          {"urn:uuid:" + canonicalJobID, ErrMalformedID},
+	"no hyphens":   {"0b7d5a4e3f0c4c1e9a8e5d6f7a8b9c0d", ErrMalformedID},
+	"non-hex":      {"0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0g", ErrMalformedID},
+	"truncated":    {canonicalJobID[:35], ErrMalformedID},
+	"spaces":       {" " + canonicalJobID[1:], ErrMalformedID},
+	"not a uuid":   {"job-1", ErrMalformedID},
+	"empty":        {"", ErrMalformedID},
+	"nil uuid":     {"00000000-0000-0000-0000-000000000000", ErrNilID},
+	"hyphens only": {"------------------------------------", ErrMalformedID},
+}
+
+func TestParseID(t *testing.T) {
+	for _, raw := range []string{canonicalJobID, "0B7D5A4E-3F0C-4C1E-9A8E-5D6F7A8B9C0D", "0b7D5a4E-3F0c-4c1E-9A8e-5d6F7a8B9c0D"} {
+		id, err := ParseID(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, canonicalJobID, id.String(), raw)
+	}
+	for name, tc := range malformedIDs {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseID(tc.raw)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+// serveCanonical serves a request for path on a route of pattern behind
+// CanonicalIDs, returning the response and the request the handler saw.
+func serveCanonical(t *testing.T, pattern, path, tenantID string) (*httptest.ResponseRecorder, *http.Request) {
+	t.Helper()
+	var seen *http.Request
+	r := mux.NewRouter()
+	r.Use(CanonicalIDs)
+	r.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = req
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if tenantID != "" {
+		req = req.WithContext(WithTenantID(req.Context(), tenantID))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, seen
+}
+
+func decodeIDError(t *testing.T, w *httptest.ResponseRecorder) IDError {
+	t.Helper()
+	var resp struct {
+		Code    string  `json:"code"`
+		Message string  `json:"message"`
+		Details IDError `json:"details"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, errCodeInvalidRequest, resp.Code)
+	assert.Contains(t, resp.Message, resp.Details.Parameter)
+	return resp.Details
+}
+
+func TestCanonicalIDs_Canonicalises(t *testing.T) {
+	w, seen := serveCanonical(t, "/analysis/{job_id}/trace/{trace_id}",
+		"/analysis/0B7D5A4E-3F0C-4C1E-9A8E-5D6F7A8B9C0D/trace/TRACE-1?baseline=6F1C2B3A-4D5E-4F60-8A9B-0C1D2E3F4A5B",
+		"6F1C2B3A-4D5E-4F60-8A9B-0C1D2E3F4A5B")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	ctx := seen.Context()
+	assert.Equal(t, canonicalTenID, GetTenantID(ctx))
+	assert.Equal(t, canonicalJobID, mux.Vars(seen)["job_id"])
+	assert.Equal(t, "TRACE-1", mux.Vars(seen)["trace_id"], "only UUID parameters are touched")
+	id, ok := GetID(ctx, "job_id")
+	assert.True(t, ok)
+	assert.Equal(t, uuid.MustParse(canonicalJobID), id)
+	id, ok = GetID(ctx, "baseline")
+	assert.True(t, ok)
+	assert.Equal(t, canonicalTenID, id.String())
+	_, ok = GetID(ctx, "candidate")
+	assert.False(t, ok, "unset query parameters are not stored")
+}
+
+func TestCanonicalIDs_RejectsMalformed(t *testing.T) {
+	for name, tc := range malformedIDs {
+		if tc.raw == "" || tc.raw[0] == ' ' {
+			continue // Not expressible in a path segment.
+		}
+		t.Run("path "+name, func(t *testing.T) {
+			w, seen := serveCanonical(t, "/exports/{export_id}", "/exports/"+tc.raw, canonicalTenID)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Nil(t, seen)
+			assert.Equal(t, IDError{Parameter: "export_id", Source: "path", Reason: tc.err.Error()}, decodeIDError(t, w))
+		})
+	}
+	for name, tc := range malformedIDs {
+		if tc.raw == "" {
+			continue // An empty query parameter is not set.
+		}
+		t.Run("query "+name, func(t *testing.T) {
+			w, seen := serveCanonical(t, "/search", "/search?job_id="+url.QueryEscape(tc.raw), canonicalTenID)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Nil(t, seen)
+			assert.Equal(t, IDError{Parameter: "job_id", Source: "query", Reason: tc.err.Error()}, decodeIDError(t, w))
+		})
+		t.Run("tenant "+name, func(t *testing.T) {
+			w, seen := serveCanonical(t, "/files", "/files", tc.raw)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Nil(t, seen)
+			assert.Equal(t, IDError{Parameter: "tenant_id", Source: "auth", Reason: tc.err.Error()}, decodeIDError(t, w))
+		})
+	}
+}
+
+func TestCanonicalIDs_NoIdentifiers(t *testing.T) {
+	w, seen := serveCanonical(t, "/files", "/files", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, GetTenantID(seen.Context()), "a missing tenant is left to TenantMiddleware")
+}
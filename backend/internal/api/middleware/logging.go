@@ -50,6 +50,12 @@ func (sr *statusRecorder) Flush() {
 	}
 }
 
+// Unwrap returns the underlying ResponseWriter, so that an
+// http.ResponseController can extend the write deadline of a stream.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
 // Push implements http.Pusher for HTTP/2 server push.
 // It delegates to the underlying ResponseWriter if it supports pushing.
 func (sr *statusRecorder) Push(target string, opts *http.PushOptions) error {
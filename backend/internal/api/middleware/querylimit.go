@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// QueryIDHeader carries the ID of a search or analytics request. It can be
+// passed to DELETE /api/v1/queries/{query_id} to cancel the request's
+// ClickHouse queries.
+const QueryIDHeader = "X-Query-ID"
+
+const (
+	// queryLease bounds how long a slot is held when the replica serving
+	// the request dies before releasing it.
+	queryLease = 10 * time.Minute
+	// queryPoll is how often a queued request retries for a slot.
+	queryPoll = 100 * time.Millisecond
+)
+
+// QuerySlotStore takes and frees the per-tenant query slots. It is
+// implemented by storage.RedisClient.
+type QuerySlotStore interface {
+	AcquireQuerySlot(ctx context.Context, q domain.InFlightQuery, limit int, lease time.Duration) (bool, error)
+	ReleaseQuerySlot(ctx context.Context, tenantID, id string) error
+}
+
+// QueryKiller cancels the ClickHouse queries of a request. It is
+// implemented by storage.ClickHouseClient.
+type QueryKiller interface {
+	KillQuery(ctx context.Context, id string) error
+}
+
+// QueryLimiter gives every search and analytics request a query ID and caps
+// how many of them a tenant runs at once. A request over the cap waits
+// briefly for a slot and is then answered 429 Too Many Requests. The slots
+// live in Redis so that the cap holds across API replicas; when Redis
+// cannot be reached requests are let through and counted.
+type QueryLimiter struct {
+	store    QuerySlotStore
+	killer   QueryKiller
+	limit    int
+	maxWait  time.Duration
+	failOpen atomic.Int64
+	clock    clock.Clock
+}
+
+// NewQueryLimiter creates a QueryLimiter allowing limit concurrent queries
+// per tenant, or any number when limit is 0, and queueing requests for at
+// most maxWait. When killer is set the queries of a request whose client
+// disconnects are killed.
+func NewQueryLimiter(store QuerySlotStore, killer QueryKiller, limit int, maxWait time.Duration) *QueryLimiter {
+	return &QueryLimiter{store: store, killer: killer, limit: limit, maxWait: maxWait, clock: clock.Real}
+}
+
+// SetClock makes the limiter stamp and queue requests by c.
+func (ql *QueryLimiter) SetClock(c clock.Clock) {
+	ql.clock = c
+}
+
+// FailOpenCount returns how many requests were let through unchecked
+// because the slot store failed.
+func (ql *QueryLimiter) FailOpenCount() int64 {
+	return ql.failOpen.Load()
+}
+
+// Track returns an http.Handler that runs next holding one of the tenant's
+// query slots. It sets the X-Query-ID response header and tags the
+// ClickHouse queries of the request with it. It must be placed after
+// AuthMiddleware in the chain. CORS preflights are not tracked.
+func (ql *QueryLimiter) Track(next http.Handler) http.Handler {
+	if ql == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := GetTenantID(r.Context())
+		if r.Method == http.MethodOptions || tenantID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		q := domain.InFlightQuery{
+			ID:        uuid.NewString(),
+			TenantID:  tenantID,
+			JobID:     mux.Vars(r)["job_id"],
+			UserID:    GetUserID(r.Context()),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			StartedAt: ql.clock.Now().UTC(),
+		}
+		w.Header().Set(QueryIDHeader, q.ID)
+
+		acquired, err := ql.acquire(r.Context(), q)
+		if err != nil {
+			if r.Context().Err() != nil {
+				return // the client went away while queued
+			}
+			ql.failOpen.Add(1)
+			slog.Warn("query limiter unavailable, allowing request",
+				"tenant_id", tenantID,
+				"query_id", q.ID,
+				"fail_open_total", ql.failOpen.Load(),
+				"error", err,
+			)
+		} else if !acquired {
+			retryAfter := int(math.Ceil(ql.maxWait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeJSON(w, http.StatusTooManyRequests, errorResponse{
+				Code:    errCodeRateLimited,
+				Message: "too many concurrent queries, retry later",
+				Details: map[string]interface{}{
+					"max_concurrent": ql.limit,
+					"waited_ms":      ql.maxWait.Milliseconds(),
+				},
+			})
+			return
+		} else {
+			defer ql.release(r.Context(), q)
+		}
+
+		next.ServeHTTP(w, r.WithContext(storage.WithQueryID(r.Context(), q.ID)))
+
+		if ql.killer != nil && r.Context().Err() != nil {
+			if err := ql.killer.KillQuery(r.Context(), q.ID); err != nil {
+				slog.Warn("failed to kill queries of disconnected client", "query_id", q.ID, "error", err)
+			}
+		}
+	})
+}
+
+// acquire takes a slot for q, retrying until one is free, maxWait has
+// passed or ctx is done.
+func (ql *QueryLimiter) acquire(ctx context.Context, q domain.InFlightQuery) (bool, error) {
+	deadline := ql.clock.Now().Add(ql.maxWait)
+	for {
+		ok, err := ql.store.AcquireQuerySlot(ctx, q, ql.limit, queryLease)
+		if err != nil || ok {
+			return ok, err
+		}
+		if !ql.clock.Now().Add(queryPoll).Before(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ql.clock.After(queryPoll):
+		}
+	}
+}
+
+// release frees the slot of q, also when the request was cancelled.
+func (ql *QueryLimiter) release(ctx context.Context, q domain.InFlightQuery) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := ql.store.ReleaseQuerySlot(ctx, q.TenantID, q.ID); err != nil {
+		slog.Warn("failed to release query slot", "tenant_id", q.TenantID, "query_id", q.ID, "error", err)
+	}
+}
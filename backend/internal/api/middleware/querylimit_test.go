@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+type recordingKiller struct {
+	mu     sync.Mutex
+	killed []string
+}
+
+func (k *recordingKiller) KillQuery(ctx context.Context, id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.killed = append(k.killed, id)
+	return nil
+}
+
+// blockingHandler holds its requests until release is closed, reporting on
+// started once each is running.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestQueryLimiter_Track(t *testing.T) {
+	store := newTestRedis(t, miniredis.RunT(t))
+	ql := NewQueryLimiter(store, nil, 2, 0)
+
+	var seen string
+	rr := serve(ql.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = storage.QueryIDFrom(r.Context())
+		queries, err := store.ListQuerySlots(r.Context(), "t1")
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		assert.Equal(t, seen, queries[0].ID)
+		assert.Equal(t, "/api/v1/analysis/job-1/search", queries[0].Path)
+		w.WriteHeader(http.StatusOK)
+	})), tenantRequest(http.MethodGet, "t1"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, rr.Header().Get(QueryIDHeader))
+
+	queries, err := store.ListQuerySlots(context.Background(), "t1")
+	require.NoError(t, err)
+	assert.Empty(t, queries, "the slot is released when the request ends")
+
+	// Preflights and requests without a tenant are not tracked.
+	assert.Empty(t, serve(ql.Track(okHandler()), tenantRequest(http.MethodOptions, "t1")).Header().Get(QueryIDHeader))
+	assert.Empty(t, serve(ql.Track(okHandler()), tenantRequest(http.MethodGet, "")).Header().Get(QueryIDHeader))
+}
+
+func TestQueryLimiter_CapUnderParallelRequests(t *testing.T) {
+	ql := NewQueryLimiter(newTestRedis(t, miniredis.RunT(t)), nil, 3, 0)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	h := ql.Track(blockingHandler(started, release))
+
+	var ok, limited atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := serve(h, tenantRequest(http.MethodGet, "t1"))
+			switch rr.Code {
+			case http.StatusOK:
+				ok.Add(1)
+			case http.StatusTooManyRequests:
+				assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+				limited.Add(1)
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	require.Eventually(t, func() bool { return limited.Load() == 7 }, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(3), ok.Load())
+
+	// Other tenants have their own slots.
+	assert.Equal(t, http.StatusOK, serve(ql.Track(okHandler()), tenantRequest(http.MethodGet, "t2")).Code)
+}
+
+func TestQueryLimiter_QueuesBriefly(t *testing.T) {
+	ql := NewQueryLimiter(newTestRedis(t, miniredis.RunT(t)), nil, 1, 5*time.Second)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	h := ql.Track(blockingHandler(started, release))
+
+	first := make(chan int)
+	go func() { first <- serve(h, tenantRequest(http.MethodGet, "t1")).Code }()
+	<-started
+
+	second := make(chan int)
+	go func() { second <- serve(ql.Track(okHandler()), tenantRequest(http.MethodGet, "t1")).Code }()
+	time.Sleep(3 * queryPoll)
+	close(release)
+
+	assert.Equal(t, http.StatusOK, <-first)
+	assert.Equal(t, http.StatusOK, <-second, "the queued request gets the freed slot")
+}
+
+func TestQueryLimiter_KillsOnDisconnect(t *testing.T) {
+	killer := &recordingKiller{}
+	ql := NewQueryLimiter(newTestRedis(t, miniredis.RunT(t)), killer, 2, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := tenantRequest(http.MethodGet, "t1").WithContext(WithTenantID(ctx, "t1"))
+	rr := serve(ql.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel() // the client goes away mid-query
+	})), req)
+
+	require.Len(t, killer.killed, 1)
+	assert.Equal(t, rr.Header().Get(QueryIDHeader), killer.killed[0])
+
+	serve(ql.Track(okHandler()), tenantRequest(http.MethodGet, "t1"))
+	assert.Len(t, killer.killed, 1, "completed requests are not killed")
+}
+
+type failingSlotStore struct{}
+
+func (failingSlotStore) AcquireQuerySlot(context.Context, domain.InFlightQuery, int, time.Duration) (bool, error) {
+	return false, errors.New("redis down")
+}
+
+func (failingSlotStore) ReleaseQuerySlot(context.Context, string, string) error { return nil }
+
+func TestQueryLimiter_FailOpen(t *testing.T) {
+	ql := NewQueryLimiter(failingSlotStore{}, nil, 1, 0)
+	rr := serve(ql.Track(okHandler()), tenantRequest(http.MethodGet, "t1"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get(QueryIDHeader))
+	assert.Equal(t, int64(1), ql.FailOpenCount())
+
+	var nilLimiter *QueryLimiter
+	assert.Equal(t, http.StatusOK, serve(nilLimiter.Track(okHandler()), tenantRequest(http.MethodGet, "t1")).Code)
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// RateLimitClass groups endpoints that share a rate limit per tenant.
+type RateLimitClass string
+
+const (
+	RateLimitSearch    RateLimitClass = "search"    // log search, facets, histogram, autocomplete
+	RateLimitAnalytics RateLimitClass = "analytics" // dashboard sections, traces, comparisons
+	RateLimitAI        RateLimitClass = "ai"        // AI queries and streams
+)
+
+// RateLimitClasses lists the classes in display order.
+var RateLimitClasses = []RateLimitClass{RateLimitSearch, RateLimitAnalytics, RateLimitAI}
+
+// TokenBucketStore takes tokens from shared token buckets. It is
+// implemented by storage.RedisClient.
+type TokenBucketStore interface {
+	TakeToken(ctx context.Context, key string, bucket storage.TokenBucket) (storage.TokenBucketState, error)
+	PeekToken(ctx context.Context, key string, bucket storage.TokenBucket) (storage.TokenBucketState, error)
+}
+
+// RateLimiter limits each tenant to a token bucket per endpoint class. The
+// buckets live in Redis so that the limits hold across API replicas. When
+// Redis cannot be reached requests are let through and counted.
+type RateLimiter struct {
+	store TokenBucketStore
+	// limit returns the bucket of a class at the time of a request, and
+	// false when the class is not limited.
+	limit    func(class RateLimitClass) (storage.TokenBucket, bool)
+	failOpen atomic.Int64
+}
+
+// NewRateLimiter creates a RateLimiter with fixed limits. Classes without
+// a limit are not limited.
+func NewRateLimiter(store TokenBucketStore, limits map[RateLimitClass]storage.TokenBucket) *RateLimiter {
+	return &RateLimiter{store: store, limit: func(class RateLimitClass) (storage.TokenBucket, bool) {
+		bucket, ok := limits[class]
+		return bucket, ok
+	}}
+}
+
+// NewDynamicRateLimiter creates a RateLimiter whose limits are read from
+// settings on every request, so that changes apply without a restart.
+// Every class is limited.
+func NewDynamicRateLimiter(store TokenBucketStore, settings config.Dynamic) *RateLimiter {
+	return &RateLimiter{store: store, limit: func(class RateLimitClass) (storage.TokenBucket, bool) {
+		return storage.PerMinute(settings.RateLimit(string(class))), true
+	}}
+}
+
+// FailOpenCount returns how many requests were let through unchecked
+// because the bucket store failed.
+func (rl *RateLimiter) FailOpenCount() int64 {
+	return rl.failOpen.Load()
+}
+
+// bucketKey follows the RedisClient.TenantKey layout.
+func bucketKey(tenantID string, class RateLimitClass) string {
+	return "remedyiq:" + tenantID + ":ratelimit:" + string(class)
+}
+
+// Limit returns an http.Handler middleware that takes a token from the
+// tenant's bucket of class and answers 429 Too Many Requests with a
+// Retry-After header when it is empty. It must be placed after
+// AuthMiddleware in the chain. CORS preflights are not counted.
+func (rl *RateLimiter) Limit(class RateLimitClass) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if rl == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID := GetTenantID(r.Context())
+			if r.Method == http.MethodOptions || tenantID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bucket, ok := rl.limit(class)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			state, err := rl.store.TakeToken(r.Context(), bucketKey(tenantID, class), bucket)
+			if err != nil {
+				rl.failOpen.Add(1)
+				slog.Warn("rate limiter unavailable, allowing request",
+					"class", class,
+					"tenant_id", tenantID,
+					"fail_open_total", rl.failOpen.Load(),
+					"error", err,
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !state.Allowed {
+				retryAfter := int(math.Ceil(state.RetryAfter.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeJSON(w, http.StatusTooManyRequests, errorResponse{
+					Code:    errCodeRateLimited,
+					Message: "too many " + string(class) + " requests, retry later",
+					Details: map[string]interface{}{
+						"class":          class,
+						"retry_after_ms": state.RetryAfter.Milliseconds(),
+						"rate_per_sec":   bucket.Rate,
+						"burst":          bucket.Burst,
+					},
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitBucket is the state of one bucket as shown by the debug handler.
+type RateLimitBucket struct {
+	Class  RateLimitClass `json:"class"`
+	Rate   float64        `json:"rate_per_sec"`
+	Burst  int            `json:"burst"`
+	Tokens *float64       `json:"tokens"` // nil when the store could not be read
+}
+
+// DebugHandler serves the buckets of a tenant, given by the tenant_id query
+// parameter or else the caller's tenant, without taking tokens.
+func (rl *RateLimiter) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.URL.Query().Get("tenant_id")
+		if tenantID == "" {
+			tenantID = GetTenantID(r.Context())
+		}
+		if tenantID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "tenant_id is required")
+			return
+		}
+
+		buckets := make([]RateLimitBucket, 0, len(RateLimitClasses))
+		for _, class := range RateLimitClasses {
+			bucket, ok := rl.limit(class)
+			if !ok {
+				continue
+			}
+			b := RateLimitBucket{Class: class, Rate: bucket.Rate, Burst: bucket.Burst}
+			if state, err := rl.store.PeekToken(r.Context(), bucketKey(tenantID, class), bucket); err == nil {
+				b.Tokens = &state.Tokens
+			} else {
+				slog.Warn("failed to read rate limit bucket", "class", class, "tenant_id", tenantID, "error", err)
+			}
+			buckets = append(buckets, b)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"tenant_id":       tenantID,
+			"buckets":         buckets,
+			"fail_open_total": rl.FailOpenCount(),
+		})
+	})
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+func newTestRedis(t *testing.T, mr *miniredis.Miniredis) *storage.RedisClient {
+	t.Helper()
+	client, err := storage.NewRedisClient(context.Background(), "redis://"+mr.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func tenantRequest(method, tenantID string) *http.Request {
+	req := httptest.NewRequest(method, "/api/v1/analysis/job-1/search", nil)
+	if tenantID != "" {
+		req = req.WithContext(WithTenantID(req.Context(), tenantID))
+	}
+	return req
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRateLimiter_Limit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC))
+	rl := NewRateLimiter(newTestRedis(t, mr), map[RateLimitClass]storage.TokenBucket{
+		RateLimitSearch: storage.PerMinute(6, 2),
+		RateLimitAI:     storage.PerMinute(6, 1),
+	})
+	search := rl.Limit(RateLimitSearch)(okHandler())
+
+	assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+	assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+
+	rr := serve(search, tenantRequest(http.MethodGet, "t1"))
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+
+	var body struct {
+		Code    string                 `json:"code"`
+		Message string                 `json:"message"`
+		Details map[string]interface{} `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, errCodeRateLimited, body.Code)
+	assert.Equal(t, "search", body.Details["class"])
+	assert.Equal(t, float64(10000), body.Details["retry_after_ms"])
+	assert.Equal(t, float64(2), body.Details["burst"])
+
+	t.Run("other tenants have their own bucket", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t2")).Code)
+	})
+
+	t.Run("other classes have their own bucket", func(t *testing.T) {
+		ai := rl.Limit(RateLimitAI)(okHandler())
+		assert.Equal(t, http.StatusOK, serve(ai, tenantRequest(http.MethodGet, "t1")).Code)
+	})
+
+	t.Run("classes without a limit pass", func(t *testing.T) {
+		analytics := rl.Limit(RateLimitAnalytics)(okHandler())
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, serve(analytics, tenantRequest(http.MethodGet, "t1")).Code)
+		}
+	})
+
+	t.Run("preflights and anonymous requests pass", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodOptions, "t1")).Code)
+		assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "")).Code)
+	})
+
+	t.Run("the bucket refills", func(t *testing.T) {
+		mr.SetTime(time.Date(2026, 2, 3, 10, 0, 10, 0, time.UTC))
+		assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+	})
+}
+
+func TestRateLimiter_DynamicLimits(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC))
+	settings := config.NewDynamicSettings(&config.Config{RateLimitSearchPerMin: 6, RateLimitSearchBurst: 1})
+	search := NewDynamicRateLimiter(newTestRedis(t, mr), settings).Limit(RateLimitSearch)(okHandler())
+
+	assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+
+	// At 6 a minute a second refills a tenth of a token; at 60, one.
+	_, err := settings.Apply(&domain.ServerSettings{Version: 1, Settings: map[string]json.RawMessage{
+		"rate_limit.search.per_min": json.RawMessage("60"),
+	}})
+	require.NoError(t, err)
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 1, 0, time.UTC))
+	assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+}
+
+func TestRateLimiter_FailOpen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rl := NewRateLimiter(newTestRedis(t, mr), map[RateLimitClass]storage.TokenBucket{
+		RateLimitSearch: storage.PerMinute(6, 1),
+	})
+	search := rl.Limit(RateLimitSearch)(okHandler())
+	mr.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(search, tenantRequest(http.MethodGet, "t1")).Code)
+	}
+	assert.Equal(t, int64(3), rl.FailOpenCount())
+}
+
+func TestRateLimiter_Nil(t *testing.T) {
+	var rl *RateLimiter
+	h := rl.Limit(RateLimitSearch)(okHandler())
+	assert.Equal(t, http.StatusOK, serve(h, tenantRequest(http.MethodGet, "t1")).Code)
+}
+
+// TestRateLimiter_SharedAcrossInstances runs two limiters, as two API
+// replicas would, against one Redis and checks that together they admit no
+// more than the burst.
+func TestRateLimiter_SharedAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC))
+	limits := map[RateLimitClass]storage.TokenBucket{
+		RateLimitSearch: storage.PerMinute(1, 10),
+	}
+	handlers := []http.Handler{
+		NewRateLimiter(newTestRedis(t, mr), limits).Limit(RateLimitSearch)(okHandler()),
+		NewRateLimiter(newTestRedis(t, mr), limits).Limit(RateLimitSearch)(okHandler()),
+	}
+
+	const requests = 50
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(h http.Handler) {
+			defer wg.Done()
+			codes <- serve(h, tenantRequest(http.MethodGet, "t1")).Code
+		}(handlers[i%len(handlers)])
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	assert.Equal(t, 10, counts[http.StatusOK])
+	assert.Equal(t, requests-10, counts[http.StatusTooManyRequests])
+}
+
+func TestRateLimiter_DebugHandler(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC))
+	rl := NewRateLimiter(newTestRedis(t, mr), map[RateLimitClass]storage.TokenBucket{
+		RateLimitSearch: storage.PerMinute(6, 5),
+		RateLimitAI:     storage.PerMinute(6, 2),
+	})
+	search := rl.Limit(RateLimitSearch)(okHandler())
+	serve(search, tenantRequest(http.MethodGet, "t1"))
+	serve(search, tenantRequest(http.MethodGet, "t1"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/debug/ratelimit?tenant_id=t1", nil)
+	rr := serve(rl.DebugHandler(), req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var body struct {
+		TenantID      string            `json:"tenant_id"`
+		Buckets       []RateLimitBucket `json:"buckets"`
+		FailOpenTotal int64             `json:"fail_open_total"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "t1", body.TenantID)
+	require.Len(t, body.Buckets, 2)
+	assert.Equal(t, RateLimitSearch, body.Buckets[0].Class)
+	require.NotNil(t, body.Buckets[0].Tokens)
+	assert.InDelta(t, 3, *body.Buckets[0].Tokens, 0.001)
+	assert.Equal(t, RateLimitAI, body.Buckets[1].Class)
+	require.NotNil(t, body.Buckets[1].Tokens)
+	assert.InDelta(t, 2, *body.Buckets[1].Tokens, 0.001)
+
+	t.Run("tenant is required", func(t *testing.T) {
+		rr := serve(rl.DebugHandler(), httptest.NewRequest(http.MethodGet, "/api/v1/admin/debug/ratelimit", nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
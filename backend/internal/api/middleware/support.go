@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// ActAsTenantHeader names the tenant a support engineer acts in.
+	ActAsTenantHeader = "X-Act-As-Tenant"
+	// ActingAsTenantHeader is set on responses to requests made in another
+	// tenant under a support grant.
+	ActingAsTenantHeader = "X-Acting-As-Tenant"
+
+	// ImpersonationKey is the context key for the Impersonation of a request.
+	ImpersonationKey contextKey = "impersonation"
+)
+
+// Impersonation describes a request a support engineer makes in another
+// tenant under a support grant. The tenant ID of the request context is
+// then the impersonated tenant.
+type Impersonation struct {
+	ActorTenantID string // Home tenant of the support engineer
+	GrantID       uuid.UUID
+	ReadOnly      bool
+}
+
+// GetImpersonation returns the Impersonation of the request, or nil when the
+// user acts in their own tenant.
+func GetImpersonation(ctx context.Context) *Impersonation {
+	v, _ := ctx.Value(ImpersonationKey).(*Impersonation)
+	return v
+}
+
+// WithImpersonation returns a new context with the given impersonation set.
+func WithImpersonation(ctx context.Context, imp *Impersonation) context.Context {
+	return context.WithValue(ctx, ImpersonationKey, imp)
+}
+
+// SupportGrantStore looks up support grants and keeps the audit of what
+// support did under them. It is implemented by storage.PostgresClient.
+type SupportGrantStore interface {
+	GetActiveSupportGrant(ctx context.Context, tenantID uuid.UUID) (*domain.SupportGrant, error)
+	RecordSupportAccess(ctx context.Context, ev *domain.SupportAccessEvent) error
+}
+
+// SupportAccessMiddleware lets platform support engineers act in a tenant
+// that granted them access. It must be placed after AuthMiddleware and
+// before TenantMiddleware in the chain.
+type SupportAccessMiddleware struct {
+	store   SupportGrantStore
+	userIDs map[string]bool
+	now     func() time.Time
+}
+
+// NewSupportAccessMiddleware creates a SupportAccessMiddleware for the given
+// support users. With no users configured the header is always ignored.
+func NewSupportAccessMiddleware(store SupportGrantStore, supportUserIDs []string) *SupportAccessMiddleware {
+	ids := make(map[string]bool, len(supportUserIDs))
+	for _, id := range supportUserIDs {
+		if id != "" {
+			ids[id] = true
+		}
+	}
+	return &SupportAccessMiddleware{store: store, userIDs: ids, now: time.Now}
+}
+
+// IsSupport reports whether userID belongs to a platform support engineer.
+func (sm *SupportAccessMiddleware) IsSupport(userID string) bool {
+	return sm.userIDs[userID]
+}
+
+// ActAsTenant returns an http.Handler middleware that switches the tenant of
+// requests carrying X-Act-As-Tenant to that tenant, provided the user is a
+// support engineer and the tenant has an active grant. Otherwise the header
+// is ignored and the request goes on in the user's own tenant. Requests
+// made under a grant are recorded in the tenant's support access audit;
+// writes under a read-only grant are refused with 403 Forbidden.
+func (sm *SupportAccessMiddleware) ActAsTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get(ActAsTenantHeader)
+		if target == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID := GetUserID(r.Context())
+		grant := sm.activeGrant(r, userID, target)
+		if grant == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		imp := &Impersonation{
+			ActorTenantID: GetTenantID(r.Context()),
+			GrantID:       grant.ID,
+			ReadOnly:      grant.ReadOnly,
+		}
+		ev := &domain.SupportAccessEvent{
+			TenantID:      grant.TenantID,
+			GrantID:       grant.ID,
+			ActorUserID:   userID,
+			ActorTenantID: imp.ActorTenantID,
+			Method:        r.Method,
+			Path:          r.URL.Path,
+		}
+		defer sm.record(r.Context(), ev)
+
+		if grant.ReadOnly && !isReadMethod(r.Method) {
+			ev.Status = http.StatusForbidden
+			writeError(w, http.StatusForbidden, errCodeForbidden, "support access to this tenant is read-only")
+			return
+		}
+
+		ctx := WithTenantID(r.Context(), grant.TenantID.String())
+		ctx = WithOrgID(ctx, grant.TenantID.String())
+		ctx = WithOrgRole(ctx, "")
+		ctx = WithImpersonation(ctx, imp)
+		w.Header().Set(ActingAsTenantHeader, grant.TenantID.String())
+
+		rec := newStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		ev.Status = rec.statusCode
+	})
+}
+
+// activeGrant returns the grant under which userID may act in target, or nil
+// with the reason logged.
+func (sm *SupportAccessMiddleware) activeGrant(r *http.Request, userID, target string) *domain.SupportGrant {
+	refuse := func(reason string) *domain.SupportGrant {
+		slog.Warn("support access header ignored",
+			"reason", reason,
+			"user_id", userID,
+			"target_tenant_id", target,
+			"path", r.URL.Path,
+		)
+		return nil
+	}
+
+	if !sm.IsSupport(userID) {
+		return refuse("not a support user")
+	}
+	tenantID, err := ParseID(target)
+	if err != nil {
+		return refuse("invalid tenant ID")
+	}
+	if strings.EqualFold(tenantID.String(), GetTenantID(r.Context())) {
+		return nil // Already in that tenant.
+	}
+	grant, err := sm.store.GetActiveSupportGrant(r.Context(), tenantID)
+	if err != nil || grant == nil {
+		if err != nil && !storage.IsNotFound(err) {
+			slog.Error("failed to look up support grant", "tenant_id", tenantID, "error", err)
+		}
+		return refuse("no active grant")
+	}
+	if grant.TenantID != tenantID || !grant.Active(sm.now()) {
+		return refuse("grant expired or revoked")
+	}
+	return grant
+}
+
+// record writes a support access audit event. It is written even when the
+// client has gone away, as the request has been served.
+func (sm *SupportAccessMiddleware) record(ctx context.Context, ev *domain.SupportAccessEvent) {
+	ev.CreatedAt = sm.now().UTC()
+	slog.Info("support access",
+		"actor_user_id", ev.ActorUserID,
+		"actor_tenant_id", ev.ActorTenantID,
+		"tenant_id", ev.TenantID,
+		"grant_id", ev.GrantID,
+		"method", ev.Method,
+		"path", ev.Path,
+		"status", ev.Status,
+	)
+	if err := sm.store.RecordSupportAccess(context.WithoutCancel(ctx), ev); err != nil {
+		slog.Error("failed to record support access", "tenant_id", ev.TenantID, "grant_id", ev.GrantID, "error", err)
+	}
+}
+
+func isReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
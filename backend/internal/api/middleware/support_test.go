@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// fakeGrantStore serves one grant and keeps the audit events recorded.
+type fakeGrantStore struct {
+	grant   *domain.SupportGrant
+	err     error
+	lookups int
+	events  []domain.SupportAccessEvent
+}
+
+func (s *fakeGrantStore) GetActiveSupportGrant(_ context.Context, tenantID uuid.UUID) (*domain.SupportGrant, error) {
+	s.lookups++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.grant == nil || s.grant.TenantID != tenantID {
+		return nil, fmt.Errorf("postgres: active support grant not found: %s", tenantID)
+	}
+	return s.grant, nil
+}
+
+func (s *fakeGrantStore) RecordSupportAccess(_ context.Context, ev *domain.SupportAccessEvent) error {
+	s.events = append(s.events, *ev)
+	return nil
+}
+
+var supportEpoch = time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+
+func newSupportTest(grant *domain.SupportGrant) (*SupportAccessMiddleware, *fakeGrantStore) {
+	store := &fakeGrantStore{grant: grant}
+	sm := NewSupportAccessMiddleware(store, []string{"support-1"})
+	sm.now = func() time.Time { return supportEpoch }
+	return sm, store
+}
+
+// serveAsTenant sends a request from userID of tenant home with
+// X-Act-As-Tenant set to target, and returns the response and the context
+// the handler saw, nil if it was not called.
+func serveAsTenant(sm *SupportAccessMiddleware, method, userID, home, target string) (*httptest.ResponseRecorder, context.Context) {
+	var seen context.Context
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Context()
+		w.WriteHeader(http.StatusTeapot)
+	})
+	req := httptest.NewRequest(method, "/api/v1/analysis", nil)
+	req.Header.Set(ActAsTenantHeader, target)
+	req = req.WithContext(WithTenantID(WithUserID(req.Context(), userID), home))
+	w := httptest.NewRecorder()
+	sm.ActAsTenant(inner).ServeHTTP(w, req)
+	return w, seen
+}
+
+func TestSupportAccess_WithGrant(t *testing.T) {
+	tenant := uuid.New()
+	grant := &domain.SupportGrant{ID: uuid.New(), TenantID: tenant, ExpiresAt: supportEpoch.Add(time.Hour)}
+	sm, store := newSupportTest(grant)
+
+	w, ctx := serveAsTenant(sm, http.MethodPost, "support-1", "support-tenant", tenant.String())
+
+	require.NotNil(t, ctx)
+	assert.Equal(t, tenant.String(), GetTenantID(ctx))
+	assert.Equal(t, "support-1", GetUserID(ctx))
+	assert.Equal(t, &Impersonation{ActorTenantID: "support-tenant", GrantID: grant.ID}, GetImpersonation(ctx))
+	assert.False(t, IsTenantAdmin(ctx))
+	assert.Equal(t, tenant.String(), w.Header().Get(ActingAsTenantHeader))
+
+	require.Len(t, store.events, 1)
+	assert.Equal(t, domain.SupportAccessEvent{
+		TenantID:      tenant,
+		GrantID:       grant.ID,
+		ActorUserID:   "support-1",
+		ActorTenantID: "support-tenant",
+		Method:        http.MethodPost,
+		Path:          "/api/v1/analysis",
+		Status:        http.StatusTeapot,
+		CreatedAt:     supportEpoch,
+	}, store.events[0])
+}
+
+func TestSupportAccess_HeaderIgnored(t *testing.T) {
+	tenant := uuid.New()
+	active := func() *domain.SupportGrant {
+		return &domain.SupportGrant{ID: uuid.New(), TenantID: tenant, ExpiresAt: supportEpoch.Add(time.Hour)}
+	}
+	revokedAt := supportEpoch.Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		grant       *domain.SupportGrant
+		storeErr    error
+		userID      string
+		target      string
+		wantLookups int
+	}{
+		{name: "no grant", userID: "support-1", target: tenant.String(), wantLookups: 1},
+		{name: "grant of another tenant", grant: &domain.SupportGrant{TenantID: uuid.New(), ExpiresAt: supportEpoch.Add(time.Hour)}, userID: "support-1", target: tenant.String(), wantLookups: 1},
+		{name: "expired grant", grant: &domain.SupportGrant{TenantID: tenant, ExpiresAt: supportEpoch}, userID: "support-1", target: tenant.String(), wantLookups: 1},
+		{name: "revoked grant", grant: &domain.SupportGrant{TenantID: tenant, ExpiresAt: supportEpoch.Add(time.Hour), RevokedAt: &revokedAt}, userID: "support-1", target: tenant.String(), wantLookups: 1},
+		{name: "store error", storeErr: errors.New("connection refused"), userID: "support-1", target: tenant.String(), wantLookups: 1},
+		{name: "not a support user", grant: active(), userID: "user-1", target: tenant.String()},
+		{name: "invalid tenant ID", grant: active(), userID: "support-1", target: "not-a-uuid"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sm, store := newSupportTest(tc.grant)
+			store.err = tc.storeErr
+
+			w, ctx := serveAsTenant(sm, http.MethodGet, tc.userID, "home-tenant", tc.target)
+
+			require.NotNil(t, ctx, "the request goes on in the user's own tenant")
+			assert.Equal(t, http.StatusTeapot, w.Code)
+			assert.Equal(t, "home-tenant", GetTenantID(ctx))
+			assert.Nil(t, GetImpersonation(ctx))
+			assert.Empty(t, w.Header().Get(ActingAsTenantHeader))
+			assert.Equal(t, tc.wantLookups, store.lookups)
+			assert.Empty(t, store.events)
+		})
+	}
+}
+
+func TestSupportAccess_ReadOnly(t *testing.T) {
+	tenant := uuid.New()
+	grant := &domain.SupportGrant{ID: uuid.New(), TenantID: tenant, ReadOnly: true, ExpiresAt: supportEpoch.Add(time.Hour)}
+
+	t.Run("reads pass", func(t *testing.T) {
+		sm, store := newSupportTest(grant)
+		_, ctx := serveAsTenant(sm, http.MethodGet, "support-1", "support-tenant", tenant.String())
+		require.NotNil(t, ctx)
+		assert.True(t, GetImpersonation(ctx).ReadOnly)
+		require.Len(t, store.events, 1)
+	})
+
+	t.Run("writes are refused and audited", func(t *testing.T) {
+		sm, store := newSupportTest(grant)
+		w, ctx := serveAsTenant(sm, http.MethodDelete, "support-1", "support-tenant", tenant.String())
+		assert.Nil(t, ctx)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		require.Len(t, store.events, 1)
+		assert.Equal(t, http.StatusForbidden, store.events[0].Status)
+		assert.Equal(t, http.MethodDelete, store.events[0].Method)
+	})
+}
+
+func TestSupportAccess_NoHeader(t *testing.T) {
+	sm, store := newSupportTest(nil)
+	called := false
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis", nil)
+	req = req.WithContext(WithTenantID(WithUserID(req.Context(), "support-1"), "support-tenant"))
+	sm.ActAsTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Equal(t, "support-tenant", GetTenantID(r.Context()))
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, called)
+	assert.Zero(t, store.lookups)
+}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 // TenantMiddleware ensures that every authenticated request has a valid tenant
@@ -31,7 +33,8 @@ func (tm *TenantMiddleware) InjectTenant(next http.Handler) http.Handler {
 		}
 
 		// Tenant ID is valid and present -- allow the request through.
-		// Downstream handlers can retrieve it with middleware.GetTenantID(ctx).
-		next.ServeHTTP(w, r)
+		// Downstream handlers can retrieve it with middleware.GetTenantID(ctx),
+		// and their ClickHouse statements run on the tenant's cluster.
+		next.ServeHTTP(w, r.WithContext(storage.WithTenant(r.Context(), tenantID)))
 	})
 }
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// uploadLease bounds how long a slot is held when the replica serving
+	// the upload dies; running uploads renew it every third of it.
+	uploadLease = 2 * time.Minute
+	// uploadRetryAfter is the Retry-After of an upload over the cap. Uploads
+	// take long, so there is no point in queueing them.
+	uploadRetryAfter = 10 * time.Second
+)
+
+// UploadSlotStore takes and frees the per-tenant upload slots. It is
+// implemented by storage.RedisClient.
+type UploadSlotStore interface {
+	AcquireUploadSlot(ctx context.Context, u domain.InFlightUpload, limit int, lease time.Duration) (bool, error)
+	RenewUploadSlot(ctx context.Context, tenantID, id string, lease time.Duration) error
+	ReleaseUploadSlot(ctx context.Context, tenantID, id string) error
+	ListUploadSlots(ctx context.Context, tenantID string) ([]domain.InFlightUpload, error)
+}
+
+// UploadLimiter caps how many file uploads a tenant has in flight and
+// shapes the bandwidth of their bodies, so that one tenant's bulk upload
+// does not starve the uploads of the others. An upload over the cap is
+// answered 429 Too Many Requests at once. The slots live in Redis so that
+// the cap holds across API replicas; when Redis cannot be reached uploads
+// are let through and counted. Bandwidth is shaped on each replica.
+type UploadLimiter struct {
+	store    UploadSlotStore
+	limit    int
+	shaper   *BandwidthShaper
+	failOpen atomic.Int64
+	clock    clock.Clock
+}
+
+// NewUploadLimiter creates an UploadLimiter allowing limit concurrent
+// uploads per tenant, or any number when limit is 0. shaper may be nil to
+// leave the bandwidth unshaped.
+func NewUploadLimiter(store UploadSlotStore, limit int, shaper *BandwidthShaper) *UploadLimiter {
+	return &UploadLimiter{store: store, limit: limit, shaper: shaper, clock: clock.Real}
+}
+
+// SetClock makes the limiter stamp uploads, and renew their slots, by c.
+func (ul *UploadLimiter) SetClock(c clock.Clock) {
+	ul.clock = c
+}
+
+// FailOpenCount returns how many uploads were let through unchecked
+// because the slot store failed.
+func (ul *UploadLimiter) FailOpenCount() int64 {
+	return ul.failOpen.Load()
+}
+
+// Limit returns an http.Handler that runs next holding one of the tenant's
+// upload slots, with the request body shaped. It must be placed after
+// AuthMiddleware in the chain. CORS preflights are not counted.
+func (ul *UploadLimiter) Limit(next http.Handler) http.Handler {
+	if ul == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := GetTenantID(r.Context())
+		if r.Method == http.MethodOptions || tenantID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := domain.InFlightUpload{
+			ID:            uuid.NewString(),
+			TenantID:      tenantID,
+			UserID:        GetUserID(r.Context()),
+			ContentLength: r.ContentLength,
+			StartedAt:     ul.clock.Now().UTC(),
+		}
+		acquired, err := ul.store.AcquireUploadSlot(r.Context(), u, ul.limit, uploadLease)
+		switch {
+		case err != nil:
+			ul.failOpen.Add(1)
+			slog.Warn("upload limiter unavailable, allowing upload",
+				"tenant_id", tenantID,
+				"upload_id", u.ID,
+				"fail_open_total", ul.failOpen.Load(),
+				"error", err,
+			)
+		case !acquired:
+			w.Header().Set("Retry-After", strconv.Itoa(int(uploadRetryAfter.Seconds())))
+			writeJSON(w, http.StatusTooManyRequests, errorResponse{
+				Code:    errCodeRateLimited,
+				Message: "too many concurrent uploads, retry later",
+				Details: map[string]interface{}{
+					"max_concurrent": ul.limit,
+				},
+			})
+			return
+		default:
+			stop := ul.renew(r.Context(), u)
+			defer func() {
+				stop()
+				ul.release(r.Context(), u)
+			}()
+		}
+
+		body := ul.shaper.Wrap(r.Context(), tenantID, r.Body)
+		defer body.Close()
+		r.Body = body
+		next.ServeHTTP(w, r)
+	})
+}
+
+// renew keeps the slot of u leased until the returned func is called.
+func (ul *UploadLimiter) renew(ctx context.Context, u domain.InFlightUpload) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := ul.clock.NewTicker(uploadLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if err := ul.store.RenewUploadSlot(ctx, u.TenantID, u.ID, uploadLease); err != nil {
+					slog.Warn("failed to renew upload slot", "tenant_id", u.TenantID, "upload_id", u.ID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// release frees the slot of u, also when the request was cancelled.
+func (ul *UploadLimiter) release(ctx context.Context, u domain.InFlightUpload) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := ul.store.ReleaseUploadSlot(ctx, u.TenantID, u.ID); err != nil {
+		slog.Warn("failed to release upload slot", "tenant_id", u.TenantID, "upload_id", u.ID, "error", err)
+	}
+}
+
+// TenantUploadLimits returns the upload limits of the tenant: its uploads
+// in flight across replicas and its bandwidth on this replica.
+func (ul *UploadLimiter) TenantUploadLimits(ctx context.Context, tenantID string) *domain.UploadLimits {
+	limits := ul.UploadLimits()
+	tenant := ul.shaper.TenantState(tenantID)
+	limits.BytesPerSec, limits.BurstBytes, limits.AvailableBytes = tenant.Rate, tenant.Burst, tenant.Available
+	limits.ActiveStreams = tenant.Streams
+
+	uploads, err := ul.store.ListUploadSlots(ctx, tenantID)
+	if err != nil {
+		slog.Warn("failed to list upload slots", "tenant_id", tenantID, "error", err)
+		return limits
+	}
+	inFlight := len(uploads)
+	limits.InFlight, limits.Uploads = &inFlight, uploads
+	return limits
+}
+
+// UploadLimits returns the limits of this replica as a whole.
+func (ul *UploadLimiter) UploadLimits() *domain.UploadLimits {
+	global := ul.shaper.GlobalState()
+	limits := &domain.UploadLimits{
+		MaxConcurrent:     ul.limit,
+		GlobalBytesPerSec: global.Rate,
+		GlobalAvailable:   global.Available,
+		ActiveStreams:     global.Streams,
+		FailOpenTotal:     ul.FailOpenCount(),
+	}
+	if ul.shaper != nil {
+		limits.BytesPerSec, limits.BurstBytes = ul.shaper.tenantRate, ul.shaper.tenantBurst
+	}
+	return limits
+}
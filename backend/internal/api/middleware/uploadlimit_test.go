@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func uploadRequest(tenantID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", strings.NewReader(body))
+	return req.WithContext(WithTenantID(req.Context(), tenantID))
+}
+
+func TestUploadLimiter_CapUnderParallelUploads(t *testing.T) {
+	store := newTestRedis(t, miniredis.RunT(t))
+	ul := NewUploadLimiter(store, 2, nil)
+	started := make(chan struct{}, 8)
+	release := make(chan struct{})
+	h := ul.Limit(blockingHandler(started, release))
+
+	var ok, limited atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := serve(h, uploadRequest("t1", "log"))
+			switch rr.Code {
+			case http.StatusOK:
+				ok.Add(1)
+			case http.StatusTooManyRequests:
+				assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+				limited.Add(1)
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		<-started
+	}
+	require.Eventually(t, func() bool { return limited.Load() == 6 }, 5*time.Second, 10*time.Millisecond)
+
+	limits := ul.TenantUploadLimits(context.Background(), "t1")
+	require.NotNil(t, limits.InFlight)
+	assert.Equal(t, 2, *limits.InFlight)
+	assert.Equal(t, 2, limits.MaxConcurrent)
+
+	// Other tenants have their own slots.
+	assert.Equal(t, http.StatusOK, serve(ul.Limit(okHandler()), uploadRequest("t2", "log")).Code)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(2), ok.Load())
+
+	uploads, err := store.ListUploadSlots(context.Background(), "t1")
+	require.NoError(t, err)
+	assert.Empty(t, uploads, "slots are released when the uploads end")
+}
+
+func TestUploadLimiter_ShapesBody(t *testing.T) {
+	shaper, clock := newTestShaper(1<<10, 1<<10, 0, 0)
+	ul := NewUploadLimiter(newTestRedis(t, miniredis.RunT(t)), 0, shaper)
+	start := clock.Now()
+
+	var got int
+	rr := serve(ul.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got = len(b)
+		assert.Equal(t, 1, ul.TenantUploadLimits(r.Context(), "t1").ActiveStreams)
+		w.WriteHeader(http.StatusCreated)
+	})), uploadRequest("t1", strings.Repeat("x", 3<<10)))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, 3<<10, got)
+	assert.Equal(t, 2*time.Second, clock.Now().Sub(start), "3 KiB at 1 KiB/s after a 1 KiB burst")
+	assert.Zero(t, ul.UploadLimits().ActiveStreams)
+}
+
+type failingUploadStore struct{}
+
+func (failingUploadStore) AcquireUploadSlot(context.Context, domain.InFlightUpload, int, time.Duration) (bool, error) {
+	return false, errors.New("redis down")
+}
+
+func (failingUploadStore) RenewUploadSlot(context.Context, string, string, time.Duration) error {
+	return errors.New("redis down")
+}
+
+func (failingUploadStore) ReleaseUploadSlot(context.Context, string, string) error { return nil }
+
+func (failingUploadStore) ListUploadSlots(context.Context, string) ([]domain.InFlightUpload, error) {
+	return nil, errors.New("redis down")
+}
+
+func TestUploadLimiter_FailOpen(t *testing.T) {
+	ul := NewUploadLimiter(failingUploadStore{}, 1, nil)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve(ul.Limit(okHandler()), uploadRequest("t1", "log")).Code)
+	}
+	assert.Equal(t, int64(3), ul.FailOpenCount())
+
+	limits := ul.TenantUploadLimits(context.Background(), "t1")
+	assert.Nil(t, limits.InFlight, "in-flight uploads cannot be counted")
+	assert.Equal(t, int64(3), limits.FailOpenTotal)
+
+	var nilLimiter *UploadLimiter
+	assert.Equal(t, http.StatusOK, serve(nilLimiter.Limit(okHandler()), uploadRequest("t1", "log")).Code)
+}
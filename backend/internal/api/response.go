@@ -18,6 +18,9 @@ const (
 	ErrCodeFileTooLarge     = "file_too_large"
 	ErrCodeUnsupportedMedia = "unsupported_media_type"
 	ErrCodeConflict         = "conflict"
+	ErrCodeQuotaExceeded    = "quota_exceeded"
+	ErrCodeQueryTooBroad    = "query_too_broad"
+	ErrCodeCaptureDrift     = "capture_drift"
 )
 
 // ErrorResponse is the standard error envelope returned to clients.
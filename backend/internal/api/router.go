@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -22,41 +23,101 @@ type RouterConfig struct {
 	// ClerkSecretKey is the Clerk JWT signing secret.
 	ClerkSecretKey string
 
+	// AuthProvider, when set, verifies bearer tokens instead of Clerk; it is
+	// the local provider of deployments with AUTH_PROVIDER=local.
+	AuthProvider middleware.AuthProvider
+
+	// V1Sunset, when set, is announced in the Deprecation and Sunset headers
+	// of every /api/v1 response.
+	V1Sunset time.Time
+
+	// AdminUserIDs are the users allowed on /api/v1/admin routes.
+	AdminUserIDs []string
+
+	// JobGuard, when set, wraps every authenticated route so that analyses
+	// in the trash are answered with 404.
+	JobGuard func(http.Handler) http.Handler
+
+	// SupportAccess, when set, lets platform support engineers act in tenants
+	// that granted them access with the X-Act-As-Tenant header.
+	SupportAccess *middleware.SupportAccessMiddleware
+
+	// RateLimiter, when set, limits the search, analytics and AI routes per
+	// tenant. Its bucket state is served on /api/v1/admin/debug/ratelimit.
+	RateLimiter *middleware.RateLimiter
+
+	// QueryLimiter, when set, gives search and analytics requests a query ID
+	// and caps how many of them a tenant runs at once.
+	QueryLimiter *middleware.QueryLimiter
+
+	// UploadLimiter, when set, caps the uploads a tenant has in flight and
+	// shapes their bandwidth.
+	UploadLimiter *middleware.UploadLimiter
+
+	// Idempotency, when set, replays the stored response of the mutating
+	// routes opted into it to retries sent with the same Idempotency-Key.
+	Idempotency *middleware.Idempotency
+
 	// Handlers -----------------------------------------------------------------
 
 	// HealthHandler serves GET /api/v1/health.
 	HealthHandler http.Handler
 
+	// LoginHandler serves POST /api/v1/auth/login. It is only routed when
+	// set, i.e. with the local auth provider.
+	LoginHandler http.Handler
+
 	// File handlers
 	UploadFileHandler http.Handler // POST /api/v1/files/upload
 	ListFilesHandler  http.Handler // GET  /api/v1/files
 
 	// Analysis handlers
 	CreateAnalysisHandler     http.Handler // POST /api/v1/analysis
+	EstimateAnalysisHandler   http.Handler // POST /api/v1/analyses/estimate
+	CancelAnalysisHandler     http.Handler // POST /api/v1/analyses/{job_id}/cancel
 	ListAnalysesHandler       http.Handler // GET  /api/v1/analysis
 	GetAnalysisHandler        http.Handler // GET  /api/v1/analysis/{job_id}
+	JobEventsHandler          http.Handler // GET  /api/v1/analysis/{job_id}/events
+	DeleteAnalysisHandler     http.Handler // DELETE /api/v1/analysis/{job_id}
 	GetDashboardHandler       http.Handler // GET  /api/v1/analysis/{job_id}/dashboard
 	AggregatesHandler         http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/aggregates
 	ExceptionsHandler         http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/exceptions
+	ErrorHeatmapHandler       http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/exceptions/heatmap
 	GapsHandler               http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/gaps
 	ThreadsHandler            http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/threads
+	ThreadTimelineHandler     http.Handler // GET  /api/v1/analyses/{job_id}/threads/timeline
+	ErrorOnsetHandler         http.Handler // GET  /api/v1/analyses/{job_id}/error-onset
+	SQLTableHandler           http.Handler // GET  /api/v1/analyses/{job_id}/sql/tables/{table}
+	JARReportHandler          http.Handler // GET  /api/v1/analyses/{job_id}/report.{txt|md}
 	FiltersHandler            http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/filters
 	QueuedCallsHandler        http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/queued-calls
 	LoggingActivityHandler    http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/logging-activity
 	FileMetadataHandler       http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/file-metadata
 	DelayedEscalationsHandler http.Handler // GET  /api/v1/analysis/{job_id}/dashboard/delayed-escalations
 	SearchLogsHandler         http.Handler // GET  /api/v1/analysis/{job_id}/search
+	ResolveEntryHandler       http.Handler // GET  /api/v1/analysis/{job_id}/entries/resolve
 	GetLogEntryHandler        http.Handler // GET  /api/v1/analysis/{job_id}/entries/{entry_id}
 	GetEntryContextHandler    http.Handler // GET  /api/v1/analysis/{job_id}/entries/{entry_id}/context
+	ExplainEntryHandler       http.Handler // GET  /api/v1/analysis/{job_id}/entries/{entry_id}/explain
 	GetTraceHandler           http.Handler // GET  /api/v1/analysis/{job_id}/trace/{trace_id}
 	GetWaterfallHandler       http.Handler // GET  /api/v1/analysis/{job_id}/trace/{trace_id}/waterfall
 	SearchTransactionsHandler http.Handler // GET  /api/v1/analysis/{job_id}/transactions
 	ExportTraceHandler        http.Handler // GET  /api/v1/analysis/{job_id}/trace/{trace_id}/export
 	TraceAIHandler            http.Handler // POST /api/v1/analysis/{job_id}/trace/ai-analyze
 	GetRecentTracesHandler    http.Handler // GET  /api/v1/trace/recent
+	TraceDiffHandler          http.Handler // POST /api/v1/analyses/{job_id}/traces/diff
 	ExportHandler             http.Handler // GET  /api/v1/analysis/{job_id}/search/export
+	CreateSearchExportHandler http.Handler // POST /api/v1/analysis/{job_id}/exports
 	QueryAIHandler            http.Handler // POST /api/v1/analysis/{job_id}/ai
 	GenerateReportHandler     http.Handler // POST /api/v1/analysis/{job_id}/report
+	ListViolationsHandler     http.Handler // GET  /api/v1/analysis/{job_id}/violations
+	APILegendHandler          http.Handler // GET  /api/v1/analysis/{job_id}/legend
+
+	// Investigation handlers
+	UpdateInvestigationHandler  http.Handler // PATCH /api/v1/analysis/{job_id}/investigation
+	InvestigationHistoryHandler http.Handler // GET   /api/v1/analysis/{job_id}/investigation/history
+	WorkspacesHandler           http.Handler // GET|POST /api/v1/analyses/{job_id}/workspaces
+	WorkspaceHandler            http.Handler // GET|PATCH|DELETE /api/v1/analyses/{job_id}/workspaces/{workspace_id}
 
 	// Search handlers
 	AutocompleteHandler      http.Handler // GET  /api/v1/search/autocomplete
@@ -64,8 +125,103 @@ type RouterConfig struct {
 	DeleteSavedSearchHandler http.Handler // DELETE /api/v1/search/saved/{search_id}
 	SearchHistoryHandler     http.Handler // GET  /api/v1/search/history
 
-	// WebSocket handler
-	WSHandler http.Handler // GET /api/v1/ws
+	// Vocabulary handlers
+	VocabularyHandler http.Handler // GET /api/v1/vocabulary
+
+	// Comparison handlers
+	CompareAnalysesHandler        http.Handler // GET  /api/v1/analyses/compare
+	CreateComparisonExportHandler http.Handler // POST /api/v1/analyses/compare/export
+	CaptureBaselineHandler        http.Handler // PUT|DELETE /api/v1/analyses/{job_id}/capture-baseline
+
+	// Trash handlers
+	TrashHandler           http.Handler // GET  /api/v1/analyses/trash
+	RestoreAnalysisHandler http.Handler // POST /api/v1/analyses/{id}/restore
+
+	// Export handlers
+	ListSearchExportsHandler http.Handler // GET  /api/v1/exports
+	GetSearchExportHandler   http.Handler // GET  /api/v1/exports/{export_id}
+	DownloadExportHandler    http.Handler // GET  /api/v1/exports/{export_id}/download
+	ExportProvenanceHandler  http.Handler // GET  /api/v1/exports/{export_id}/provenance
+	RegenerateExportHandler  http.Handler // POST /api/v1/exports/{export_id}/regenerate
+
+	// Threshold rule handlers
+	ListThresholdRulesHandler  http.Handler // GET    /api/v1/threshold-rules
+	CreateThresholdRuleHandler http.Handler // POST   /api/v1/threshold-rules
+	UpdateThresholdRuleHandler http.Handler // PUT    /api/v1/threshold-rules/{rule_id}
+	DeleteThresholdRuleHandler http.Handler // DELETE /api/v1/threshold-rules/{rule_id}
+
+	// Ingestion filter rule handlers
+	ListIngestionFiltersHandler  http.Handler // GET    /api/v1/ingestion-filters
+	CreateIngestionFilterHandler http.Handler // POST   /api/v1/ingestion-filters
+	DryRunIngestionFilterHandler http.Handler // POST   /api/v1/ingestion-filters/dry-run
+	UpdateIngestionFilterHandler http.Handler // PUT    /api/v1/ingestion-filters/{rule_id}
+	DeleteIngestionFilterHandler http.Handler // DELETE /api/v1/ingestion-filters/{rule_id}
+
+	// Analysis link handlers
+	ListAnalysisLinksHandler          http.Handler // GET    /api/v1/analysis/{job_id}/links
+	CreateAnalysisLinkHandler         http.Handler // POST   /api/v1/analysis/{job_id}/links
+	DeleteAnalysisLinkHandler         http.Handler // DELETE /api/v1/analysis/{job_id}/links/{link_id}
+	GetTicketingIntegrationHandler    http.Handler // GET    /api/v1/integrations/{type}
+	PutTicketingIntegrationHandler    http.Handler // PUT    /api/v1/integrations/{type}
+	DeleteTicketingIntegrationHandler http.Handler // DELETE /api/v1/integrations/{type}
+
+	// Incident group handlers
+	CreateIncidentGroupHandler http.Handler // POST   /api/v1/incident-groups
+	GetIncidentGroupHandler    http.Handler // GET    /api/v1/incident-groups/{group_id}
+	SetJobIncidentGroupHandler http.Handler // PUT    /api/v1/analysis/{job_id}/incident-group
+
+	// In-flight queries
+	ListQueriesHandler http.Handler // GET    /api/v1/analysis/{job_id}/queries
+	KillQueryHandler   http.Handler // DELETE /api/v1/queries/{query_id}
+
+	// Digest handlers
+	DigestSubscriptionHandler http.Handler // GET/PUT /api/v1/digest/subscription
+
+	// Preferences handlers
+	PreferencesHandler         http.Handler // GET/PUT /api/v1/preferences
+	PreferencesDefaultsHandler http.Handler // GET/PUT /api/v1/preferences/defaults
+
+	// Usage handlers
+	TenantUsageHandler http.Handler // GET /api/v1/tenants/{tenant_id}/usage
+
+	// Support access handlers (own tenant; grants and revocations by tenant administrators)
+	CreateSupportGrantHandler    http.Handler // POST   /api/v1/tenants/{tenant_id}/support-access
+	ListSupportGrantsHandler     http.Handler // GET    /api/v1/tenants/{tenant_id}/support-access
+	RevokeSupportGrantHandler    http.Handler // DELETE /api/v1/tenants/{tenant_id}/support-access/{grant_id}
+	SupportAccessActivityHandler http.Handler // GET    /api/v1/tenants/{tenant_id}/support-access/activity
+
+	// Tenant export handlers (administrators only)
+	CreateTenantExportHandler   http.Handler // POST /api/v1/tenants/{tenant_id}/export
+	GetTenantExportHandler      http.Handler // GET  /api/v1/tenants/{tenant_id}/exports/{export_id}
+	DownloadTenantExportHandler http.Handler // GET  /api/v1/tenants/{tenant_id}/exports/{export_id}/download
+
+	// Tenant configuration as code (administrators only)
+	TenantConfigExportHandler http.Handler // GET /api/v1/tenants/{tenant_id}/config-export
+	TenantConfigImportHandler http.Handler // PUT /api/v1/tenants/{tenant_id}/config-import
+
+	// SQL literal redaction policy (administrators only)
+	SQLRedactionHandler http.Handler // GET/PUT /api/v1/tenants/{tenant_id}/sql-redaction
+
+	// Admin handlers
+	TenantRetentionHandler http.Handler // GET /api/v1/admin/tenants/retention
+	HealthProfileHandler   http.Handler // GET/PUT/DELETE /api/v1/admin/tenants/{tenant_id}/health-profile
+	AllTenantsUsageHandler http.Handler // GET /api/v1/admin/usage
+	FixtureCaptureHandler  http.Handler // POST /api/v1/admin/analyses/{job_id}/capture-fixture
+	DiagnosticsHandler     http.Handler // GET /api/v1/admin/analyses/{job_id}/diagnostics
+	TenantRegionHandler    http.Handler // PUT /api/v1/admin/tenants/{tenant_id}/region
+	RegionsHandler         http.Handler // GET /api/v1/admin/regions
+	QueueStatusHandler     http.Handler // GET /api/v1/admin/queue-status
+	SettingsHandler        http.Handler // GET/PUT /api/v1/admin/settings
+	ListTasksHandler       http.Handler // GET /api/v1/admin/tasks
+	RunTaskNowHandler      http.Handler // POST /api/v1/admin/tasks/{name}/run-now
+	CreateTenantHandler    http.Handler // POST /api/v1/admin/tenants
+	GetTenantHandler       http.Handler // GET /api/v1/admin/tenants/{tenant_id}
+	ConvertSandboxHandler  http.Handler // POST /api/v1/admin/tenants/{tenant_id}/convert
+	BootstrapHandler       http.Handler // POST /api/v1/admin/bootstrap
+
+	// WebSocket handler and its server-sent events fallback
+	WSHandler  http.Handler // GET /api/v1/ws
+	SSEHandler http.Handler // GET /api/v1/stream/sse
 
 	// AI handlers
 	AIStreamHandler           http.Handler // POST /api/v1/ai/stream
@@ -86,52 +242,115 @@ func NewRouter(cfg RouterConfig) *mux.Router {
 	r.Use(middleware.CORSMiddleware(cfg.AllowedOrigins))
 	r.Use(middleware.BodyLimitMiddleware)
 
-	// ---- API v1 subrouter ------------------------------------------------
-	v1 := r.PathPrefix("/api/v1").Subrouter()
+	// ---- API versions -----------------------------------------------------
+	// Every version serves the same handlers; the version middleware adapts
+	// their responses to its wire shape.
+	mw := routeMiddleware{
+		auth:         middleware.NewAuthMiddleware(cfg.ClerkSecretKey, cfg.DevMode),
+		tenant:       middleware.NewTenantMiddleware(),
+		requireAdmin: middleware.NewAdminMiddleware(cfg.AdminUserIDs).RequireAdmin,
+	}
+	if cfg.AuthProvider != nil {
+		mw.auth = middleware.NewAuthMiddlewareWithProvider(cfg.AuthProvider, cfg.DevMode)
+	}
+	for _, version := range apiVersions(cfg) {
+		sub := r.PathPrefix("/api/" + version.Name).Subrouter()
+		sub.Use(version.Middleware)
+		mountRoutes(sub, cfg, mw)
+	}
+
+	return r
+}
+
+// routeMiddleware is the middleware shared by the routes of every API
+// version.
+type routeMiddleware struct {
+	auth         *middleware.AuthMiddleware
+	tenant       *middleware.TenantMiddleware
+	requireAdmin func(http.Handler) http.Handler
+}
 
+// mountRoutes registers the API routes on the subrouter of one version.
+func mountRoutes(version *mux.Router, cfg RouterConfig, mw routeMiddleware) {
 	// ---- Public routes (no auth) -----------------------------------------
-	v1.Handle("/health", handlerOrStub(cfg.HealthHandler)).Methods(http.MethodGet, http.MethodOptions)
+	version.Handle("/health", handlerOrStub(cfg.HealthHandler)).Methods(http.MethodGet, http.MethodOptions)
+	if cfg.LoginHandler != nil {
+		version.Handle("/auth/login", cfg.LoginHandler).Methods(http.MethodPost, http.MethodOptions)
+	}
 
 	// ---- Authenticated routes --------------------------------------------
-	auth := v1.NewRoute().Subrouter()
-	authMW := middleware.NewAuthMiddleware(cfg.ClerkSecretKey, cfg.DevMode)
-	tenantMW := middleware.NewTenantMiddleware()
-	auth.Use(authMW.Authenticate)
-	auth.Use(tenantMW.InjectTenant)
+	auth := version.NewRoute().Subrouter()
+	auth.Use(mw.auth.Authenticate)
+	if cfg.SupportAccess != nil {
+		auth.Use(cfg.SupportAccess.ActAsTenant)
+	}
+	auth.Use(middleware.CanonicalIDs)
+	auth.Use(mw.tenant.InjectTenant)
+	if cfg.JobGuard != nil {
+		auth.Use(cfg.JobGuard)
+	}
+	limit := func(class middleware.RateLimitClass, h http.Handler) http.Handler {
+		if class == middleware.RateLimitSearch || class == middleware.RateLimitAnalytics {
+			h = cfg.QueryLimiter.Track(h)
+		}
+		return cfg.RateLimiter.Limit(class)(h)
+	}
+	// once opts a mutating route into idempotency keys.
+	once := cfg.Idempotency.Idempotent
 
 	// Files
-	auth.Handle("/files/upload", handlerOrStub(cfg.UploadFileHandler)).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/files/upload", cfg.UploadLimiter.Limit(handlerOrStub(cfg.UploadFileHandler))).Methods(http.MethodPost, http.MethodOptions)
 	auth.Handle("/files", handlerOrStub(cfg.ListFilesHandler)).Methods(http.MethodGet, http.MethodOptions)
 
 	// Analysis
-	auth.Handle("/analysis", handlerOrStub(cfg.CreateAnalysisHandler)).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analysis", once(handlerOrStub(cfg.CreateAnalysisHandler))).Methods(http.MethodPost, http.MethodOptions)
 	auth.Handle("/analysis", handlerOrStub(cfg.ListAnalysesHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/estimate", handlerOrStub(cfg.EstimateAnalysisHandler)).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/cancel", handlerOrStub(cfg.CancelAnalysisHandler)).Methods(http.MethodPost, http.MethodOptions)
 	auth.Handle("/analysis/{job_id}", handlerOrStub(cfg.GetAnalysisHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard", handlerOrStub(cfg.GetDashboardHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/aggregates", handlerOrStub(cfg.AggregatesHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/exceptions", handlerOrStub(cfg.ExceptionsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/gaps", handlerOrStub(cfg.GapsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/threads", handlerOrStub(cfg.ThreadsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/filters", handlerOrStub(cfg.FiltersHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/queued-calls", handlerOrStub(cfg.QueuedCallsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/logging-activity", handlerOrStub(cfg.LoggingActivityHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/file-metadata", handlerOrStub(cfg.FileMetadataHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/dashboard/delayed-escalations", handlerOrStub(cfg.DelayedEscalationsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/search", handlerOrStub(cfg.SearchLogsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/search/export", handlerOrStub(cfg.ExportHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}", handlerOrStub(cfg.DeleteAnalysisHandler)).Methods(http.MethodDelete)
+	auth.Handle("/analysis/{job_id}/events", handlerOrStub(cfg.JobEventsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.GetDashboardHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/aggregates", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.AggregatesHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/exceptions", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ExceptionsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/exceptions/heatmap", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ErrorHeatmapHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/gaps", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.GapsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/threads", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ThreadsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/threads/timeline", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ThreadTimelineHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/error-onset", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ErrorOnsetHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/sql/tables/{table}", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.SQLTableHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/report.{format:txt|md}", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.JARReportHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/filters", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.FiltersHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/queued-calls", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.QueuedCallsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/logging-activity", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.LoggingActivityHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/file-metadata", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.FileMetadataHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/dashboard/delayed-escalations", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.DelayedEscalationsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/search", limit(middleware.RateLimitSearch, handlerOrStub(cfg.SearchLogsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/search/export", limit(middleware.RateLimitSearch, handlerOrStub(cfg.ExportHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/exports", limit(middleware.RateLimitSearch, once(handlerOrStub(cfg.CreateSearchExportHandler)))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/violations", handlerOrStub(cfg.ListViolationsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/legend", handlerOrStub(cfg.APILegendHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/investigation", handlerOrStub(cfg.UpdateInvestigationHandler)).Methods(http.MethodPatch, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/investigation/history", handlerOrStub(cfg.InvestigationHistoryHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/workspaces", handlerOrStub(cfg.WorkspacesHandler)).Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/workspaces/{workspace_id}", handlerOrStub(cfg.WorkspaceHandler)).Methods(http.MethodGet, http.MethodPatch, http.MethodDelete, http.MethodOptions)
+	// Registered before {entry_id} so "resolve" is not captured as an ID.
+	auth.Handle("/analysis/{job_id}/entries/resolve", handlerOrStub(cfg.ResolveEntryHandler)).Methods(http.MethodGet, http.MethodOptions)
 	auth.Handle("/analysis/{job_id}/entries/{entry_id}", handlerOrStub(cfg.GetLogEntryHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/entries/{entry_id}/context", handlerOrStub(cfg.GetEntryContextHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/trace/{trace_id}", handlerOrStub(cfg.GetTraceHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/trace/{trace_id}/waterfall", handlerOrStub(cfg.GetWaterfallHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/transactions", handlerOrStub(cfg.SearchTransactionsHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/trace/{trace_id}/export", handlerOrStub(cfg.ExportTraceHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/trace/ai-analyze", handlerOrStub(cfg.TraceAIHandler)).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/entries/{entry_id}/context", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.GetEntryContextHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/entries/{entry_id}/explain", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ExplainEntryHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/trace/{trace_id}", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.GetTraceHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/trace/{trace_id}/waterfall", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.GetWaterfallHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/transactions", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.SearchTransactionsHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/trace/{trace_id}/export", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.ExportTraceHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/trace/ai-analyze", limit(middleware.RateLimitAI, handlerOrStub(cfg.TraceAIHandler))).Methods(http.MethodPost, http.MethodOptions)
 	auth.Handle("/trace/recent", handlerOrStub(cfg.GetRecentTracesHandler)).Methods(http.MethodGet, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/ai", handlerOrStub(cfg.QueryAIHandler)).Methods(http.MethodPost, http.MethodOptions)
-	auth.Handle("/analysis/{job_id}/report", handlerOrStub(cfg.GenerateReportHandler)).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/traces/diff", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.TraceDiffHandler))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/ai", limit(middleware.RateLimitAI, handlerOrStub(cfg.QueryAIHandler))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/report", limit(middleware.RateLimitAnalytics, once(handlerOrStub(cfg.GenerateReportHandler)))).Methods(http.MethodPost, http.MethodOptions)
 
 	// AI streaming
-	auth.Handle("/ai/stream", handlerOrStub(cfg.AIStreamHandler)).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/ai/stream", limit(middleware.RateLimitAI, handlerOrStub(cfg.AIStreamHandler))).Methods(http.MethodPost, http.MethodOptions)
 	auth.Handle("/ai/skills", handlerOrStub(cfg.ListSkillsHandler)).Methods(http.MethodGet, http.MethodOptions)
 
 	// Conversations
@@ -139,15 +358,115 @@ func NewRouter(cfg RouterConfig) *mux.Router {
 	auth.Handle("/ai/conversations/{id}", handlerOrStub(cfg.ConversationDetailHandler)).Methods(http.MethodGet, http.MethodDelete, http.MethodOptions)
 
 	// Search
-	auth.Handle("/search/autocomplete", handlerOrStub(cfg.AutocompleteHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/search/autocomplete", limit(middleware.RateLimitSearch, handlerOrStub(cfg.AutocompleteHandler))).Methods(http.MethodGet, http.MethodOptions)
 	auth.Handle("/search/saved", handlerOrStub(cfg.SavedSearchHandler)).Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
 	auth.Handle("/search/saved/{search_id}", handlerOrStub(cfg.DeleteSavedSearchHandler)).Methods(http.MethodDelete, http.MethodOptions)
 	auth.Handle("/search/history", handlerOrStub(cfg.SearchHistoryHandler)).Methods(http.MethodGet, http.MethodOptions)
 
+	// Tenant vocabulary
+	auth.Handle("/vocabulary", limit(middleware.RateLimitSearch, handlerOrStub(cfg.VocabularyHandler))).Methods(http.MethodGet, http.MethodOptions)
+
+	// Comparisons
+	auth.Handle("/analyses/compare", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.CompareAnalysesHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/compare/export", limit(middleware.RateLimitAnalytics, once(handlerOrStub(cfg.CreateComparisonExportHandler)))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/analyses/{job_id}/capture-baseline", handlerOrStub(cfg.CaptureBaselineHandler)).Methods(http.MethodPut, http.MethodDelete, http.MethodOptions)
+
+	// Trash
+	auth.Handle("/analyses/trash", handlerOrStub(cfg.TrashHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analyses/{id}/restore", once(handlerOrStub(cfg.RestoreAnalysisHandler))).Methods(http.MethodPost, http.MethodOptions)
+
+	// Exports
+	auth.Handle("/exports", handlerOrStub(cfg.ListSearchExportsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/exports/{export_id}", handlerOrStub(cfg.GetSearchExportHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/exports/{export_id}/download", handlerOrStub(cfg.DownloadExportHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/exports/{export_id}/provenance", handlerOrStub(cfg.ExportProvenanceHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/exports/{export_id}/regenerate", limit(middleware.RateLimitSearch, once(handlerOrStub(cfg.RegenerateExportHandler)))).Methods(http.MethodPost, http.MethodOptions)
+
+	// Threshold rules
+	auth.Handle("/threshold-rules", handlerOrStub(cfg.ListThresholdRulesHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/threshold-rules", handlerOrStub(cfg.CreateThresholdRuleHandler)).Methods(http.MethodPost)
+	auth.Handle("/threshold-rules/{rule_id}", handlerOrStub(cfg.UpdateThresholdRuleHandler)).Methods(http.MethodPut, http.MethodOptions)
+	auth.Handle("/threshold-rules/{rule_id}", handlerOrStub(cfg.DeleteThresholdRuleHandler)).Methods(http.MethodDelete)
+
+	// Ingestion filter rules. The dry run counts entries of a stored job, one
+	// ClickHouse query per rule.
+	auth.Handle("/ingestion-filters", handlerOrStub(cfg.ListIngestionFiltersHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/ingestion-filters", handlerOrStub(cfg.CreateIngestionFilterHandler)).Methods(http.MethodPost)
+	auth.Handle("/ingestion-filters/dry-run", limit(middleware.RateLimitAnalytics, handlerOrStub(cfg.DryRunIngestionFilterHandler))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/ingestion-filters/{rule_id}", handlerOrStub(cfg.UpdateIngestionFilterHandler)).Methods(http.MethodPut, http.MethodOptions)
+	auth.Handle("/ingestion-filters/{rule_id}", handlerOrStub(cfg.DeleteIngestionFilterHandler)).Methods(http.MethodDelete)
+
+	// Analysis links
+	auth.Handle("/analysis/{job_id}/links", handlerOrStub(cfg.ListAnalysisLinksHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/links", handlerOrStub(cfg.CreateAnalysisLinkHandler)).Methods(http.MethodPost)
+	auth.Handle("/analysis/{job_id}/links/{link_id}", handlerOrStub(cfg.DeleteAnalysisLinkHandler)).Methods(http.MethodDelete, http.MethodOptions)
+	auth.Handle("/integrations/{type}", handlerOrStub(cfg.GetTicketingIntegrationHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/integrations/{type}", handlerOrStub(cfg.PutTicketingIntegrationHandler)).Methods(http.MethodPut)
+	auth.Handle("/integrations/{type}", handlerOrStub(cfg.DeleteTicketingIntegrationHandler)).Methods(http.MethodDelete)
+
+	// Incident groups. The dashboard and gaps endpoints overlay the other
+	// log sources of a job's group with ?include_group=true.
+	auth.Handle("/incident-groups", once(handlerOrStub(cfg.CreateIncidentGroupHandler))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/incident-groups/{group_id}", handlerOrStub(cfg.GetIncidentGroupHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/analysis/{job_id}/incident-group", handlerOrStub(cfg.SetJobIncidentGroupHandler)).Methods(http.MethodPut, http.MethodOptions)
+
+	// In-flight queries
+	auth.Handle("/analysis/{job_id}/queries", handlerOrStub(cfg.ListQueriesHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/queries/{query_id}", handlerOrStub(cfg.KillQueryHandler)).Methods(http.MethodDelete, http.MethodOptions)
+
+	// Digest
+	auth.Handle("/digest/subscription", handlerOrStub(cfg.DigestSubscriptionHandler)).Methods(http.MethodGet, http.MethodPut, http.MethodOptions)
+
+	// Preferences (tenant defaults are changed by tenant administrators)
+	auth.Handle("/preferences", handlerOrStub(cfg.PreferencesHandler)).Methods(http.MethodGet, http.MethodPut, http.MethodOptions)
+	auth.Handle("/preferences/defaults", handlerOrStub(cfg.PreferencesDefaultsHandler)).Methods(http.MethodGet, http.MethodPut, http.MethodOptions)
+
+	// Usage (own tenant, or any tenant for administrators)
+	auth.Handle("/tenants/{tenant_id}/usage", handlerOrStub(cfg.TenantUsageHandler)).Methods(http.MethodGet, http.MethodOptions)
+
+	// Support access
+	auth.Handle("/tenants/{tenant_id}/support-access", handlerOrStub(cfg.ListSupportGrantsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/tenants/{tenant_id}/support-access", handlerOrStub(cfg.CreateSupportGrantHandler)).Methods(http.MethodPost)
+	auth.Handle("/tenants/{tenant_id}/support-access/activity", handlerOrStub(cfg.SupportAccessActivityHandler)).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/tenants/{tenant_id}/support-access/{grant_id}", handlerOrStub(cfg.RevokeSupportGrantHandler)).Methods(http.MethodDelete, http.MethodOptions)
+
+	// Tenant exports (restricted to AdminUserIDs)
+	auth.Handle("/tenants/{tenant_id}/export", mw.requireAdmin(once(handlerOrStub(cfg.CreateTenantExportHandler)))).Methods(http.MethodPost, http.MethodOptions)
+	auth.Handle("/tenants/{tenant_id}/exports/{export_id}", mw.requireAdmin(handlerOrStub(cfg.GetTenantExportHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/tenants/{tenant_id}/exports/{export_id}/download", mw.requireAdmin(handlerOrStub(cfg.DownloadTenantExportHandler))).Methods(http.MethodGet, http.MethodOptions)
+
+	// Tenant configuration as code (restricted to AdminUserIDs)
+	auth.Handle("/tenants/{tenant_id}/config-export", mw.requireAdmin(handlerOrStub(cfg.TenantConfigExportHandler))).Methods(http.MethodGet, http.MethodOptions)
+	auth.Handle("/tenants/{tenant_id}/config-import", mw.requireAdmin(handlerOrStub(cfg.TenantConfigImportHandler))).Methods(http.MethodPut, http.MethodOptions)
+
+	// SQL literal redaction policy (restricted to AdminUserIDs)
+	auth.Handle("/tenants/{tenant_id}/sql-redaction", mw.requireAdmin(handlerOrStub(cfg.SQLRedactionHandler))).Methods(http.MethodGet, http.MethodPut, http.MethodOptions)
+
+	// Admin (platform-wide, restricted to AdminUserIDs)
+	admin := auth.PathPrefix("/admin").Subrouter()
+	admin.Use(mw.requireAdmin)
+	admin.Handle("/tenants/retention", handlerOrStub(cfg.TenantRetentionHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/tenants", handlerOrStub(cfg.CreateTenantHandler)).Methods(http.MethodPost, http.MethodOptions)
+	admin.Handle("/tenants/{tenant_id}", handlerOrStub(cfg.GetTenantHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/tenants/{tenant_id}/convert", handlerOrStub(cfg.ConvertSandboxHandler)).Methods(http.MethodPost, http.MethodOptions)
+	admin.Handle("/tenants/{tenant_id}/health-profile", handlerOrStub(cfg.HealthProfileHandler)).Methods(http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodOptions)
+	admin.Handle("/usage", handlerOrStub(cfg.AllTenantsUsageHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/analyses/{job_id}/capture-fixture", handlerOrStub(cfg.FixtureCaptureHandler)).Methods(http.MethodPost, http.MethodOptions)
+	admin.Handle("/analyses/{job_id}/diagnostics", handlerOrStub(cfg.DiagnosticsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/tenants/{tenant_id}/region", handlerOrStub(cfg.TenantRegionHandler)).Methods(http.MethodPut, http.MethodOptions)
+	admin.Handle("/regions", handlerOrStub(cfg.RegionsHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/queue-status", handlerOrStub(cfg.QueueStatusHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/settings", handlerOrStub(cfg.SettingsHandler)).Methods(http.MethodGet, http.MethodPut, http.MethodOptions)
+	admin.Handle("/tasks", handlerOrStub(cfg.ListTasksHandler)).Methods(http.MethodGet, http.MethodOptions)
+	admin.Handle("/tasks/{name}/run-now", handlerOrStub(cfg.RunTaskNowHandler)).Methods(http.MethodPost, http.MethodOptions)
+	admin.Handle("/bootstrap", handlerOrStub(cfg.BootstrapHandler)).Methods(http.MethodPost, http.MethodOptions)
+	if cfg.RateLimiter != nil {
+		admin.Handle("/debug/ratelimit", cfg.RateLimiter.DebugHandler()).Methods(http.MethodGet, http.MethodOptions)
+	}
+
 	// WebSocket
 	auth.Handle("/ws", handlerOrStub(cfg.WSHandler)).Methods(http.MethodGet)
-
-	return r
+	auth.Handle("/stream/sse", handlerOrStub(cfg.SSEHandler)).Methods(http.MethodGet)
 }
 
 // handlerOrStub returns the provided handler if non-nil, otherwise a stub
@@ -98,7 +98,7 @@ func TestNewRouter_StubEndpoints(t *testing.T) {
 		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
 			req := httptest.NewRequest(tc.method, tc.path, nil)
 			req.Header.Set("X-Dev-User-ID", "test-user")
-			req.Header.Set("X-Dev-Tenant-ID", "test-tenant")
+			req.Header.Set("X-Dev-Tenant-ID", "00000000-0000-0000-0000-000000000001")
 
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
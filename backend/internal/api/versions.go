@@ -0,0 +1,333 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// APIVersion is one version of the REST API. Every version mounts the same
+// handler set under /api/<Name>; the handlers write one canonical shape and
+// Adapt translates their JSON bodies into the wire shape of the version.
+type APIVersion struct {
+	// Name is the path segment of the version, e.g. "v1".
+	Name string
+
+	// Adapt rewrites the JSON bodies of the version's responses. Nil
+	// passes them through untouched.
+	Adapt ResponseAdapter
+
+	// Sunset is the date the version is retired. When set, its responses
+	// carry Deprecation and Sunset headers and link to Successor.
+	Sunset    time.Time
+	Successor string
+}
+
+// ResponseAdapter translates a JSON response body written with the given
+// status into the wire shape of an API version.
+type ResponseAdapter func(status int, body []byte) ([]byte, error)
+
+// Versions of the REST API, oldest first.
+const (
+	VersionV1 = "v1"
+	VersionV2 = "v2"
+)
+
+// apiVersions returns the versions the router mounts. The handlers write
+// the v1 shapes, so v1 is served as written and v2 through AdaptV2.
+func apiVersions(cfg RouterConfig) []APIVersion {
+	return []APIVersion{
+		{Name: VersionV1, Sunset: cfg.V1Sunset, Successor: VersionV2},
+		{Name: VersionV2, Adapt: AdaptV2},
+	}
+}
+
+// Middleware sets the deprecation headers of the version and adapts the
+// JSON responses of the handlers it wraps.
+func (v APIVersion) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !v.Sunset.IsZero() {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			if v.Successor != "" {
+				successor := "/api/" + v.Successor + strings.TrimPrefix(r.URL.Path, "/api/"+v.Name)
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			}
+		}
+		if v.Adapt == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		aw := &adaptingWriter{ResponseWriter: w, adapt: v.Adapt, version: v.Name}
+		next.ServeHTTP(aw, r)
+		aw.finish()
+	})
+}
+
+// adaptingWriter holds back JSON responses until the handler is done so
+// that they can be adapted as a whole. Other responses -- downloads, event
+// streams, WebSocket upgrades -- are written through as they come.
+type adaptingWriter struct {
+	http.ResponseWriter
+	adapt   ResponseAdapter
+	version string
+
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+}
+
+func (aw *adaptingWriter) WriteHeader(status int) {
+	if aw.wroteHeader {
+		return
+	}
+	aw.wroteHeader = true
+	aw.status = status
+	if isJSONContentType(aw.Header().Get("Content-Type")) {
+		aw.buffering = true
+		return
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+func (aw *adaptingWriter) Write(b []byte) (int, error) {
+	if !aw.wroteHeader {
+		aw.WriteHeader(http.StatusOK)
+	}
+	if aw.buffering {
+		return aw.buf.Write(b)
+	}
+	return aw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher for streaming responses. Buffered JSON is
+// only written once the handler returns.
+func (aw *adaptingWriter) Flush() {
+	if aw.buffering {
+		return
+	}
+	if f, ok := aw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for WebSocket connections.
+func (aw *adaptingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := aw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter, so that an
+// http.ResponseController can extend the write deadline of a stream.
+func (aw *adaptingWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+// finish adapts and writes a buffered response. A body the adapter cannot
+// read is written as the handler wrote it.
+func (aw *adaptingWriter) finish() {
+	if !aw.buffering {
+		return
+	}
+	body := aw.buf.Bytes()
+	if adapted, err := aw.adapt(aw.status, body); err != nil {
+		slog.Warn("response left unadapted", "version", aw.version, "status", aw.status, "error", err)
+	} else {
+		body = adapted
+	}
+	aw.Header().Del("Content-Length")
+	aw.ResponseWriter.WriteHeader(aw.status)
+	if _, err := aw.ResponseWriter.Write(body); err != nil {
+		slog.Error("failed to write adapted response", "version", aw.version, "error", err)
+	}
+}
+
+func isJSONContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err == nil && mediaType == "application/json"
+}
+
+// ErrorEnvelopeV2 is the error body of v2 responses: the v1 error nested
+// under "error" along with the HTTP status.
+type ErrorEnvelopeV2 struct {
+	Error ErrorBodyV2 `json:"error"`
+}
+
+// ErrorBodyV2 describes the error of a v2 response.
+type ErrorBodyV2 struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Status  int             `json:"status"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// AdaptV2 translates a v1 body into its v2 shape:
+//   - errors are wrapped in ErrorEnvelopeV2;
+//   - every timestamp is written as domain.TimestampLayout, including the
+//     RFC 3339 times of jobs, files and other stored records;
+//   - durations are only served as integer milliseconds: a field with a
+//     "<name>_ms" sibling, such as the JAR's "log_duration" string or
+//     "gap_duration" seconds, is left out.
+func AdaptV2(status int, body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := writeV2Value(dec, &out); err != nil {
+		return nil, fmt.Errorf("adapt v2: %w", err)
+	}
+	if status >= http.StatusBadRequest {
+		if wrapped, ok := wrapErrorV2(status, out.Bytes()); ok {
+			return wrapped, nil
+		}
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// wrapErrorV2 nests an ErrorResponse body under "error". Bodies of other
+// shapes are not errors of this API and are reported as not wrapped.
+func wrapErrorV2(status int, body []byte) ([]byte, bool) {
+	var e struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details json.RawMessage `json:"details"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.Code == "" {
+		return nil, false
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	if err := enc.Encode(ErrorEnvelopeV2{Error: ErrorBodyV2{
+		Code: e.Code, Message: e.Message, Status: status, Details: e.Details,
+	}}); err != nil {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+// writeV2Value copies the next JSON value of dec to out, keeping the order
+// of object members and the digits of numbers.
+func writeV2Value(dec *json.Decoder, out *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			return writeV2Array(dec, out)
+		}
+		return writeV2Object(dec, out)
+	case string:
+		return writeJSONString(out, normalizeTimestamp(t))
+	case json.Number:
+		out.WriteString(t.String())
+	case bool:
+		if t {
+			out.WriteString("true")
+		} else {
+			out.WriteString("false")
+		}
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+func writeV2Array(dec *json.Decoder, out *bytes.Buffer) error {
+	out.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := writeV2Value(dec, out); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+func writeV2Object(dec *json.Decoder, out *bytes.Buffer) error {
+	type member struct {
+		key   string
+		value []byte
+	}
+	var members []member
+	keys := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		var value bytes.Buffer
+		if err := writeV2Value(dec, &value); err != nil {
+			return err
+		}
+		members = append(members, member{key: key, value: value.Bytes()})
+		keys[key] = true
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	out.WriteByte('{')
+	first := true
+	for _, m := range members {
+		if keys[m.key+"_ms"] {
+			continue
+		}
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		if err := writeJSONString(out, m.key); err != nil {
+			return err
+		}
+		out.WriteByte(':')
+		out.Write(m.value)
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	out.Write(b)
+	return nil
+}
+
+// normalizeTimestamp rewrites an RFC 3339 time as domain.TimestampLayout
+// and returns any other string unchanged.
+func normalizeTimestamp(s string) string {
+	if len(s) < len("2006-01-02T15:04:05Z") || s[4] != '-' || s[10] != 'T' {
+		return s
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return s
+	}
+	return domain.NewTimestamp(t).String()
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files in testdata")
+
+// versionTime is not UTC and carries nanoseconds, like the times Postgres
+// returns for jobs.
+var versionTime = time.Date(2026, 2, 3, 5, 0, 0, 123456789, time.FixedZone("EST", -5*3600))
+
+var versionJobID = uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+
+// versionedEndpoints are the representative endpoints pinned for every API
+// version: a stored record, an analytics response, a JAR response with
+// second and human-readable durations, and an error.
+func versionedEndpoints() []struct {
+	name    string
+	path    string
+	handler http.Handler
+} {
+	completed := versionTime.Add(10 * time.Minute)
+	logDuration := "8h 30m 45s"
+	job := domain.AnalysisJob{
+		ID: versionJobID, TenantID: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		Status: domain.JobStatusComplete, Priority: domain.JobPriorityNormal, FileID: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+		JVMHeapMB: 4096, TimeoutSeconds: 1800, ProgressPct: 100,
+		LogStart: &versionTime, LogDuration: &logDuration,
+		CreatedAt: versionTime, UpdatedAt: completed, CompletedAt: &completed,
+	}
+	dashboard := domain.DashboardData{
+		GeneralStats: domain.GeneralStatistics{
+			TotalLines: 1000, APICount: 600,
+			LogStart: domain.NewTimestamp(versionTime), LogEnd: domain.NewTimestamp(versionTime.Add(8*time.Hour + 30*time.Minute + 45*time.Second)),
+			LogDuration: logDuration, LogDurationMS: 30645000,
+		},
+		TopAPICalls:    []domain.TopNEntry{},
+		TopSQL:         []domain.TopNEntry{},
+		TopFilters:     []domain.TopNEntry{},
+		TopEscalations: []domain.TopNEntry{},
+		TimeSeries:     []domain.TimeSeriesPoint{},
+		Distribution:   map[string]map[string]int{},
+	}
+	gaps := domain.JARGapsResponse{
+		LineGaps:    []domain.JARGapEntry{{GapDuration: 0.265, GapDurationMS: 265, LineNumber: 12, TraceID: "trace-1", Timestamp: domain.NewTimestamp(versionTime), Details: "GE"}},
+		ThreadGaps:  []domain.JARGapEntry{},
+		QueueHealth: []domain.QueueHealthSummary{},
+		Source:      "jar_parsed",
+	}
+	respond := func(v interface{}) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { JSON(w, http.StatusOK, v) })
+	}
+
+	base := "/analysis/" + versionJobID.String()
+	return []struct {
+		name    string
+		path    string
+		handler http.Handler
+	}{
+		{"analysis", base, respond(job)},
+		{"dashboard", base + "/dashboard", respond(dashboard)},
+		{"gaps", base + "/dashboard/gaps", respond(gaps)},
+		{"entry_not_found", base + "/entries/e-404", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Error(w, http.StatusNotFound, ErrCodeNotFound, "log entry not found")
+		})},
+	}
+}
+
+func versionedRouter(cfg RouterConfig) http.Handler {
+	for _, e := range versionedEndpoints() {
+		switch e.name {
+		case "analysis":
+			cfg.GetAnalysisHandler = e.handler
+		case "dashboard":
+			cfg.GetDashboardHandler = e.handler
+		case "gaps":
+			cfg.GapsHandler = e.handler
+		case "entry_not_found":
+			cfg.GetLogEntryHandler = e.handler
+		}
+	}
+	cfg.AllowedOrigins = []string{"*"}
+	cfg.DevMode = true
+	return NewRouter(cfg)
+}
+
+func serveVersioned(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-Dev-User-ID", "test-user")
+	req.Header.Set("X-Dev-Tenant-ID", "00000000-0000-0000-0000-000000000001")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func assertVersionGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var got bytes.Buffer
+	require.NoError(t, json.Indent(&got, body, "", "  "))
+	got.WriteByte('\n')
+
+	path := filepath.Join("..", "..", "testdata", "api_"+name+".golden.json")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, got.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got.String())
+}
+
+// TestAPIVersions_Golden pins the wire shape of representative endpoints
+// in every API version. Run with -update-golden after intended changes.
+func TestAPIVersions_Golden(t *testing.T) {
+	h := versionedRouter(RouterConfig{})
+	for _, version := range []string{VersionV1, VersionV2} {
+		for _, e := range versionedEndpoints() {
+			t.Run(version+"/"+e.name, func(t *testing.T) {
+				w := serveVersioned(t, h, "/api/"+version+e.path)
+				assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+				assertVersionGolden(t, version+"_"+e.name, w.Body.Bytes())
+			})
+		}
+	}
+}
+
+// TestAPIVersions_V1Unchanged checks that v1 serves the bytes the handlers
+// write.
+func TestAPIVersions_V1Unchanged(t *testing.T) {
+	h := versionedRouter(RouterConfig{})
+	for _, e := range versionedEndpoints() {
+		t.Run(e.name, func(t *testing.T) {
+			direct := httptest.NewRecorder()
+			e.handler.ServeHTTP(direct, httptest.NewRequest(http.MethodGet, e.path, nil))
+
+			w := serveVersioned(t, h, "/api/v1"+e.path)
+			assert.Equal(t, direct.Code, w.Code)
+			assert.Equal(t, direct.Body.String(), w.Body.String())
+		})
+	}
+}
+
+func TestAPIVersions_V1Deprecation(t *testing.T) {
+	t.Run("announced when a sunset is set", func(t *testing.T) {
+		h := versionedRouter(RouterConfig{V1Sunset: time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)})
+		path := "/analysis/" + versionJobID.String() + "/dashboard"
+
+		w := serveVersioned(t, h, "/api/v1"+path)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+		assert.Equal(t, `</api/v2`+path+`>; rel="successor-version"`, w.Header().Get("Link"))
+
+		w = serveVersioned(t, h, "/api/v2"+path)
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+	})
+
+	t.Run("silent without a sunset", func(t *testing.T) {
+		w := serveVersioned(t, versionedRouter(RouterConfig{}), "/api/v1/analysis/"+versionJobID.String())
+		assert.Empty(t, w.Header().Get("Deprecation"))
+		assert.Empty(t, w.Header().Get("Sunset"))
+	})
+}
+
+func TestAPIVersions_V2AdaptsMiddlewareErrors(t *testing.T) {
+	router := NewRouter(RouterConfig{AllowedOrigins: []string{"*"}, ClerkSecretKey: "test-secret"})
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/files", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	var body ErrorEnvelopeV2
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, ErrCodeUnauthorized, body.Error.Code)
+	assert.Equal(t, http.StatusUnauthorized, body.Error.Status)
+}
+
+func TestAPIVersions_V2PassesNonJSONThrough(t *testing.T) {
+	csv := "line,user\n1,Demo\n"
+	router := NewRouter(RouterConfig{
+		AllowedOrigins: []string{"*"},
+		DevMode:        true,
+		ExportHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(csv))
+			w.(http.Flusher).Flush()
+		}),
+	})
+	w := serveVersioned(t, router, "/api/v2/analysis/"+versionJobID.String()+"/search/export")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, csv, w.Body.String())
+	assert.True(t, w.Flushed)
+}
+
+func TestAdaptV2(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{
+			name:   "timestamps normalized",
+			status: http.StatusOK,
+			body:   `{"created_at":"2026-02-03T05:00:00.123456789-05:00","note":"2026-02-03 is not a time","at":"2026-02-03T10:00:00Z"}`,
+			want:   `{"created_at":"2026-02-03T10:00:00.123Z","note":"2026-02-03 is not a time","at":"2026-02-03T10:00:00.000Z"}`,
+		},
+		{
+			name:   "durations only in milliseconds",
+			status: http.StatusOK,
+			body:   `{"rows":[{"avg_time":0.11,"avg_time_ms":110,"count":3}],"log_duration":"8h","log_duration_ms":28800000}`,
+			want:   `{"rows":[{"avg_time_ms":110,"count":3}],"log_duration_ms":28800000}`,
+		},
+		{
+			name:   "numbers and order kept",
+			status: http.StatusOK,
+			body:   `{"z":12345678901234567890,"a":[1.50,true,null],"m":"\u003cb\u003e"}`,
+			want:   `{"z":12345678901234567890,"a":[1.50,true,null],"m":"\u003cb\u003e"}`,
+		},
+		{
+			name:   "error envelope",
+			status: http.StatusBadRequest,
+			body:   `{"code":"invalid_request","message":"bad input","details":{"field":"q"}}`,
+			want:   `{"error":{"code":"invalid_request","message":"bad input","status":400,"details":{"field":"q"}}}`,
+		},
+		{
+			name:   "error bodies of other shapes kept",
+			status: http.StatusConflict,
+			body:   `{"status":"queued"}`,
+			want:   `{"status":"queued"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AdaptV2(tt.status, []byte(tt.body+"\n"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want+"\n", string(got))
+		})
+	}
+
+	_, err := AdaptV2(http.StatusOK, []byte(`{"truncated":`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+// Package batch writes items queued in memory to their store in batches,
+// from a background loop, so that queueing never blocks or fails the
+// operation that produces the items. It backs the usage recorder and the
+// job event appender.
+package batch
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the number of items queued before new ones are
+	// refused.
+	DefaultBufferSize = 1024
+
+	// maxBatch caps the items written in one round trip.
+	maxBatch = 100
+
+	// drainTimeout bounds the final write when the writer stops.
+	drainTimeout = 10 * time.Second
+)
+
+// Writer queues items and writes them with its write func from Run, every
+// flush interval, whenever a full batch is queued and when asked to Flush.
+// The write func handles its own errors: a batch is written once.
+type Writer[T any] struct {
+	write         func(ctx context.Context, batch []T)
+	items         chan T
+	flushNow      chan struct{}
+	flushInterval time.Duration
+	onTick        func()
+}
+
+// NewWriter creates a Writer queueing up to bufferSize items, or
+// DefaultBufferSize when it is not positive, and writing them with write at
+// least every flushInterval, which must be positive.
+func NewWriter[T any](write func(ctx context.Context, batch []T), bufferSize int, flushInterval time.Duration) *Writer[T] {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Writer[T]{
+		write:         write,
+		items:         make(chan T, bufferSize),
+		flushNow:      make(chan struct{}, 1),
+		flushInterval: flushInterval,
+	}
+}
+
+// OnTick sets fn to be called from Run after the write of every flush
+// interval. It must be called before Run.
+func (w *Writer[T]) OnTick(fn func()) {
+	w.onTick = fn
+}
+
+// Offer queues item without blocking and reports whether it was queued: it
+// is not when the buffer is full.
+func (w *Writer[T]) Offer(item T) bool {
+	select {
+	case w.items <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// Flush asks Run to write every queued item now rather than at the next
+// flush interval. It does not wait for the write.
+func (w *Writer[T]) Flush() {
+	select {
+	case w.flushNow <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of items queued.
+func (w *Writer[T]) Len() int {
+	return len(w.items)
+}
+
+// Run writes queued items until ctx is cancelled, then writes the items
+// still queued and returns.
+func (w *Writer[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, maxBatch)
+	for {
+		select {
+		case item := <-w.items:
+			batch = append(batch, item)
+			if len(batch) >= maxBatch {
+				batch = w.flush(ctx, batch)
+			}
+		case <-w.flushNow:
+			batch = w.drain(ctx, batch)
+		case <-ticker.C:
+			batch = w.flush(ctx, batch)
+			if w.onTick != nil {
+				w.onTick()
+			}
+		case <-ctx.Done():
+			drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+			defer cancel()
+			w.drain(drainCtx, batch)
+			return
+		}
+	}
+}
+
+// drain writes batch and every item queued, and returns batch emptied.
+func (w *Writer[T]) drain(ctx context.Context, batch []T) []T {
+	for {
+		select {
+		case item := <-w.items:
+			batch = append(batch, item)
+			if len(batch) >= maxBatch {
+				batch = w.flush(ctx, batch)
+			}
+		default:
+			return w.flush(ctx, batch)
+		}
+	}
+}
+
+// flush writes batch, if any, and returns it emptied.
+func (w *Writer[T]) flush(ctx context.Context, batch []T) []T {
+	if len(batch) == 0 {
+		return batch
+	}
+	w.write(ctx, batch)
+	return batch[:0]
+}
@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder keeps the sizes of the batches written.
+type recorder struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (r *recorder) write(_ context.Context, batch []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes = append(r.sizes, len(batch))
+}
+
+func (r *recorder) written() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.sizes...)
+}
+
+// runUntilStopped runs w until ctx is cancelled and waits for it.
+func runUntilStopped(w *Writer[int]) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestWriter_WritesFullBatchesAndTheRestOnStop(t *testing.T) {
+	rec := &recorder{}
+	w := NewWriter(rec.write, 1000, time.Hour)
+	for i := 0; i < maxBatch*2+5; i++ {
+		require.True(t, w.Offer(i))
+	}
+	runUntilStopped(w)()
+
+	assert.Equal(t, []int{maxBatch, maxBatch, 5}, rec.written())
+	assert.Zero(t, w.Len())
+}
+
+func TestWriter_Flush(t *testing.T) {
+	rec := &recorder{}
+	w := NewWriter(rec.write, 16, time.Hour)
+	stop := runUntilStopped(w)
+	defer stop()
+
+	w.Offer(1)
+	w.Offer(2)
+	w.Flush()
+	// The flush interval is an hour: only Flush can write.
+	require.Eventually(t, func() bool { return len(rec.written()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{2}, rec.written())
+}
+
+func TestWriter_WritesAndTicksOnInterval(t *testing.T) {
+	rec := &recorder{}
+	ticked := make(chan struct{}, 1)
+	w := NewWriter(rec.write, 16, 10*time.Millisecond)
+	w.OnTick(func() {
+		select {
+		case ticked <- struct{}{}:
+		default:
+		}
+	})
+	stop := runUntilStopped(w)
+	defer stop()
+
+	w.Offer(1)
+	require.Eventually(t, func() bool { return len(rec.written()) == 1 }, time.Second, 5*time.Millisecond)
+	select {
+	case <-ticked:
+	case <-time.After(time.Second):
+		t.Fatal("OnTick was not called")
+	}
+}
+
+func TestWriter_OfferRefusesWhenFull(t *testing.T) {
+	w := NewWriter((&recorder{}).write, 1, time.Hour)
+	assert.True(t, w.Offer(1))
+	assert.False(t, w.Offer(2), "nothing drains the buffer: the second item is refused")
+	assert.Equal(t, 1, w.Len())
+
+	assert.Equal(t, DefaultBufferSize, cap(NewWriter((&recorder{}).write, 0, time.Hour).items))
+}
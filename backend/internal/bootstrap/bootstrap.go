@@ -0,0 +1,239 @@
+// Package bootstrap verifies that an installation can analyse logs: every
+// dependency is reached, the schemas are migrated, the bucket round-trips
+// an object, the JAR starts and the auth configuration holds together,
+// and optionally a sample log goes through the real pipeline. Each check
+// runs on its own, bounded by its timeout and shielded from the panics of
+// the others, so that one broken dependency is reported alongside every
+// other problem instead of hiding them.
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// Status is the outcome of a check, and the worst outcome of a report.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	// StatusSkip is the status of a check not run because a check it
+	// needs failed.
+	StatusSkip Status = "skip"
+)
+
+// Names of the checks, in the order they run.
+const (
+	CheckPostgres         = "postgres"
+	CheckPostgresSchema   = "postgres_schema"
+	CheckClickHouse       = "clickhouse"
+	CheckClickHouseSchema = "clickhouse_schema"
+	CheckRedis            = "redis"
+	CheckObjectStorage    = "object_storage"
+	CheckNATS             = "nats"
+	CheckJAR              = "jar"
+	CheckAuth             = "auth"
+	CheckSample           = "sample_analysis"
+)
+
+// Timeouts of the checks. The sample analysis runs the JAR, whose JVM
+// alone may take seconds to start.
+const (
+	DefaultConnectTimeout = 5 * time.Second
+	DefaultCheckTimeout   = 30 * time.Second
+	DefaultSampleTimeout  = 5 * time.Minute
+)
+
+// Result is the outcome of one check. Remediation tells how to fix what
+// failed or warned.
+type Result struct {
+	Name        string         `json:"name"`
+	Status      Status         `json:"status"`
+	Message     string         `json:"message"`
+	Remediation string         `json:"remediation,omitempty"`
+	LatencyMS   int64          `json:"latency_ms"`
+	Detail      map[string]any `json:"detail,omitempty"`
+}
+
+// Report is the outcome of a bootstrap run. Status is fail when any check
+// failed, warn when any warned, and pass otherwise.
+type Report struct {
+	Status     Status    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Checks     []Result  `json:"checks"`
+}
+
+// Failed reports whether a check failed, or with strict also warned.
+func (r *Report) Failed(strict bool) bool {
+	return r.Status == StatusFail || (strict && r.Status == StatusWarn)
+}
+
+// Pinger checks connectivity to a dependency.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// SchemaStore is a database whose migrations can be checked.
+// storage.PostgresClient and storage.ClickHouseClient implement it.
+type SchemaStore interface {
+	Pinger
+	SchemaStatus(ctx context.Context) (storage.SchemaStatus, error)
+}
+
+// Streams is the NATS connection. streaming.NATSClient implements it.
+type Streams interface {
+	Ping() error
+	EnsureStreams(ctx context.Context) error
+}
+
+// JAR starts the JAR without analysing anything. jar.Runner implements it.
+type JAR interface {
+	Version(ctx context.Context) (string, error)
+}
+
+// SampleAnalysis analyses the embedded sample log end to end and removes
+// what it created. Sample implements it.
+type SampleAnalysis interface {
+	Run(ctx context.Context) ([]Stage, error)
+}
+
+// Deps are the dependencies checked. A dependency left nil fails its
+// check, with the reason Unavailable holds under the check's name when
+// it could not be set up.
+type Deps struct {
+	Postgres   SchemaStore
+	ClickHouse SchemaStore
+	Redis      Pinger
+	Objects    storage.ObjectStorage
+	NATS       Streams
+	JAR        JAR
+	Sample     SampleAnalysis
+
+	// Config holds the JAR path and the auth settings checked.
+	Config *config.Config
+
+	Unavailable map[string]error
+}
+
+// Options selects what a run does.
+type Options struct {
+	// RunSample analyses the embedded sample log after the checks.
+	RunSample bool
+	// SampleTimeout bounds the sample analysis; zero selects
+	// DefaultSampleTimeout.
+	SampleTimeout time.Duration
+}
+
+// Bootstrapper runs the checks of an installation.
+type Bootstrapper struct {
+	deps Deps
+	now  func() time.Time
+}
+
+// New creates a Bootstrapper checking deps.
+func New(deps Deps) *Bootstrapper {
+	if deps.Config == nil {
+		deps.Config = &config.Config{}
+	}
+	return &Bootstrapper{deps: deps, now: time.Now}
+}
+
+// check is one check of a run. It is skipped when a check it requires did
+// not pass or warn.
+type check struct {
+	name     string
+	requires []string
+	timeout  time.Duration
+	run      func(ctx context.Context) Result
+}
+
+// Run runs every check in order and reports on each.
+func (b *Bootstrapper) Run(ctx context.Context, opts Options) *Report {
+	return b.runChecks(ctx, b.checks(opts))
+}
+
+func (b *Bootstrapper) runChecks(ctx context.Context, checks []check) *Report {
+	start := b.now()
+	report := &Report{Status: StatusPass, StartedAt: start.UTC(), Checks: make([]Result, 0, len(checks))}
+	statuses := make(map[string]Status, len(checks))
+
+	for _, c := range checks {
+		res := b.runCheck(ctx, c, statuses)
+		statuses[c.name] = res.Status
+		report.Checks = append(report.Checks, res)
+		switch res.Status {
+		case StatusFail:
+			report.Status = StatusFail
+		case StatusWarn:
+			if report.Status == StatusPass {
+				report.Status = StatusWarn
+			}
+		}
+	}
+	report.DurationMS = b.now().Sub(start).Milliseconds()
+	return report
+}
+
+// runCheck runs c in a goroutine of its own, bounded by its timeout, and
+// turns a panic into a failure. A check overrunning its timeout is
+// reported failed without waiting for it to return.
+func (b *Bootstrapper) runCheck(ctx context.Context, c check, statuses map[string]Status) Result {
+	for _, req := range c.requires {
+		if s := statuses[req]; s != StatusPass && s != StatusWarn {
+			return Result{Name: c.name, Status: StatusSkip, Message: fmt.Sprintf("skipped: %s did not pass", req),
+				Remediation: fmt.Sprintf("fix the %s check first", req)}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	done := make(chan Result, 1)
+	start := b.now()
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				slog.Error("bootstrap check panicked", "check", c.name, "panic", p)
+				done <- Result{Status: StatusFail, Message: fmt.Sprintf("check panicked: %v", p),
+					Remediation: "report this as a bug, with the server log"}
+			}
+		}()
+		done <- c.run(ctx)
+	}()
+
+	var res Result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res = Result{Status: StatusFail, Message: fmt.Sprintf("no answer within %s", c.timeout),
+			Remediation: "check that the dependency is reachable from this host and not overloaded"}
+	}
+	res.Name = c.name
+	res.LatencyMS = b.now().Sub(start).Milliseconds()
+	return res
+}
+
+func pass(msg string) Result { return Result{Status: StatusPass, Message: msg} }
+
+func warn(msg, remediation string) Result {
+	return Result{Status: StatusWarn, Message: msg, Remediation: remediation}
+}
+
+func fail(msg, remediation string) Result {
+	return Result{Status: StatusFail, Message: msg, Remediation: remediation}
+}
+
+// unavailable is the failure of a check whose dependency is not set up.
+func (b *Bootstrapper) unavailable(name, remediation string) Result {
+	if err := b.deps.Unavailable[name]; err != nil {
+		return fail("not connected: "+err.Error(), remediation)
+	}
+	return fail("not configured", remediation)
+}
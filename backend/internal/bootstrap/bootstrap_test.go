@@ -0,0 +1,407 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+type fakeStore struct {
+	pingErr   error
+	status    storage.SchemaStatus
+	statusErr error
+}
+
+func (f *fakeStore) Ping(ctx context.Context) error { return f.pingErr }
+func (f *fakeStore) SchemaStatus(ctx context.Context) (storage.SchemaStatus, error) {
+	return f.status, f.statusErr
+}
+
+type panickingPinger struct{}
+
+func (panickingPinger) Ping(ctx context.Context) error { panic("nil connection") }
+
+// memObjects is object storage held in memory, whose operations can be
+// made to fail.
+type memObjects struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	bucketErr error
+	uploadErr error
+	deleteErr error
+	corrupt   bool
+}
+
+func newMemObjects() *memObjects { return &memObjects{objects: make(map[string][]byte)} }
+
+func (m *memObjects) CheckBucket(ctx context.Context) error { return m.bucketErr }
+
+func (m *memObjects) Upload(ctx context.Context, key string, r io.Reader, size int64) error {
+	if m.uploadErr != nil {
+		return m.uploadErr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memObjects) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	if m.corrupt {
+		data = append([]byte("x"), data...)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memObjects) Delete(ctx context.Context, key string) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memObjects) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (m *memObjects) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+func (m *memObjects) keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for k := range m.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+type fakeNATS struct{ pingErr, streamsErr error }
+
+func (f *fakeNATS) Ping() error                             { return f.pingErr }
+func (f *fakeNATS) EnsureStreams(ctx context.Context) error { return f.streamsErr }
+
+type fakeJAR struct {
+	out string
+	err error
+}
+
+func (f *fakeJAR) Version(ctx context.Context) (string, error) { return f.out, f.err }
+
+type fakeSample struct{ err error }
+
+func (f *fakeSample) Run(ctx context.Context) ([]Stage, error) {
+	return []Stage{{Name: "analyse", Status: StatusPass}}, f.err
+}
+
+var currentSchema = storage.SchemaStatus{Version: 47, Expected: 47}
+
+// healthyDeps are dependencies passing every check.
+func healthyDeps(t *testing.T) (Deps, *memObjects) {
+	jarPath := filepath.Join(t.TempDir(), "ARLogAnalyzer.jar")
+	require.NoError(t, os.WriteFile(jarPath, []byte("jar"), 0o644))
+	objects := newMemObjects()
+	return Deps{
+		Postgres:   &fakeStore{status: currentSchema},
+		ClickHouse: &fakeStore{status: storage.SchemaStatus{Version: 10, Expected: 10}},
+		Redis:      &fakeStore{},
+		Objects:    objects,
+		NATS:       &fakeNATS{},
+		JAR:        &fakeJAR{out: "ARLogAnalyzer 3.2.1"},
+		Sample:     &fakeSample{},
+		Config: &config.Config{
+			Environment:          "production",
+			StorageBackend:       "s3",
+			S3Bucket:             "remedyiq-logs",
+			JARPath:              jarPath,
+			AuthProvider:         "local",
+			LocalAuthSigningKeys: []string{strings.Repeat("k", 32)},
+			AdminUserIDs:         []string{"admin"},
+		},
+	}, objects
+}
+
+func statuses(report *Report) map[string]Status {
+	out := make(map[string]Status, len(report.Checks))
+	for _, c := range report.Checks {
+		out[c.Name] = c.Status
+	}
+	return out
+}
+
+func resultOf(t *testing.T, report *Report, name string) Result {
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s check in the report", name)
+	return Result{}
+}
+
+func TestRun_AllPass(t *testing.T) {
+	deps, objects := healthyDeps(t)
+	report := New(deps).Run(context.Background(), Options{RunSample: true})
+
+	assert.Equal(t, StatusPass, report.Status, "%+v", report.Checks)
+	names := make([]string, len(report.Checks))
+	for i, c := range report.Checks {
+		names[i] = c.Name
+		assert.Equal(t, StatusPass, c.Status, c.Name)
+	}
+	assert.Equal(t, []string{CheckPostgres, CheckPostgresSchema, CheckClickHouse, CheckClickHouseSchema, CheckRedis,
+		CheckObjectStorage, CheckNATS, CheckJAR, CheckAuth, CheckSample}, names)
+	assert.Empty(t, objects.keys(), "the probe object is deleted")
+	assert.Equal(t, "ARLogAnalyzer 3.2.1", resultOf(t, report, CheckJAR).Message)
+	assert.False(t, report.Failed(true))
+}
+
+func TestRun_SampleIsOptional(t *testing.T) {
+	deps, _ := healthyDeps(t)
+	report := New(deps).Run(context.Background(), Options{})
+	assert.NotContains(t, statuses(report), CheckSample)
+}
+
+// TestRun_EachFailure breaks one dependency at a time and checks that its
+// check reports it with a remediation while every other check still runs:
+// those that do not need it pass, those that do are skipped.
+func TestRun_EachFailure(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name    string
+		breakIt func(d *Deps, objects *memObjects)
+		want    map[string]Status
+		message string
+	}{
+		{
+			name:    "postgres unreachable",
+			breakIt: func(d *Deps, _ *memObjects) { d.Postgres = &fakeStore{pingErr: down} },
+			want:    map[string]Status{CheckPostgres: StatusFail, CheckPostgresSchema: StatusSkip, CheckSample: StatusSkip},
+			message: "connection refused",
+		},
+		{
+			name: "postgres could not be connected",
+			breakIt: func(d *Deps, _ *memObjects) {
+				d.Postgres = nil
+				d.Unavailable = map[string]error{CheckPostgres: errors.New("dial tcp: no such host")}
+			},
+			want:    map[string]Status{CheckPostgres: StatusFail, CheckPostgresSchema: StatusSkip, CheckSample: StatusSkip},
+			message: "not connected: dial tcp: no such host",
+		},
+		{
+			name:    "postgres schema missing",
+			breakIt: func(d *Deps, _ *memObjects) { d.Postgres = &fakeStore{status: storage.SchemaStatus{Expected: 47}} },
+			want:    map[string]Status{CheckPostgresSchema: StatusFail, CheckSample: StatusSkip},
+			message: "schema missing",
+		},
+		{
+			name: "postgres schema behind",
+			breakIt: func(d *Deps, _ *memObjects) {
+				d.Postgres = &fakeStore{status: storage.SchemaStatus{Version: 45, Expected: 47, Missing: []int{46, 47}}}
+			},
+			want:    map[string]Status{CheckPostgresSchema: StatusFail, CheckSample: StatusSkip},
+			message: "schema at version 45, 2 migrations missing",
+		},
+		{
+			name:    "clickhouse unreachable",
+			breakIt: func(d *Deps, _ *memObjects) { d.ClickHouse = &fakeStore{pingErr: down} },
+			want:    map[string]Status{CheckClickHouse: StatusFail, CheckClickHouseSchema: StatusSkip, CheckSample: StatusSkip},
+		},
+		{
+			name:    "clickhouse catalog unreadable",
+			breakIt: func(d *Deps, _ *memObjects) { d.ClickHouse = &fakeStore{statusErr: errors.New("access denied")} },
+			want:    map[string]Status{CheckClickHouseSchema: StatusFail, CheckSample: StatusSkip},
+		},
+		{
+			name:    "redis unreachable",
+			breakIt: func(d *Deps, _ *memObjects) { d.Redis = &fakeStore{pingErr: down} },
+			want:    map[string]Status{CheckRedis: StatusFail},
+		},
+		{
+			name:    "bucket missing",
+			breakIt: func(_ *Deps, o *memObjects) { o.bucketErr = errors.New("NotFound") },
+			want:    map[string]Status{CheckObjectStorage: StatusFail, CheckSample: StatusSkip},
+		},
+		{
+			name:    "object write denied",
+			breakIt: func(_ *Deps, o *memObjects) { o.uploadErr = errors.New("AccessDenied") },
+			want:    map[string]Status{CheckObjectStorage: StatusFail, CheckSample: StatusSkip},
+			message: "write: AccessDenied",
+		},
+		{
+			name:    "object read back differs",
+			breakIt: func(_ *Deps, o *memObjects) { o.corrupt = true },
+			want:    map[string]Status{CheckObjectStorage: StatusFail, CheckSample: StatusSkip},
+		},
+		{
+			name:    "object delete denied",
+			breakIt: func(_ *Deps, o *memObjects) { o.deleteErr = errors.New("AccessDenied") },
+			want:    map[string]Status{CheckObjectStorage: StatusFail, CheckSample: StatusSkip},
+			message: "probe object not deleted",
+		},
+		{
+			name:    "nats unreachable",
+			breakIt: func(d *Deps, _ *memObjects) { d.NATS = &fakeNATS{pingErr: errors.New("nats: not connected")} },
+			want:    map[string]Status{CheckNATS: StatusFail},
+		},
+		{
+			name:    "nats streams not created",
+			breakIt: func(d *Deps, _ *memObjects) { d.NATS = &fakeNATS{streamsErr: errors.New("insufficient resources")} },
+			want:    map[string]Status{CheckNATS: StatusFail},
+			message: "create streams",
+		},
+		{
+			name:    "JAR missing",
+			breakIt: func(d *Deps, _ *memObjects) { d.Config.JARPath = "/opt/missing/ARLogAnalyzer.jar" },
+			want:    map[string]Status{CheckJAR: StatusFail, CheckSample: StatusSkip},
+		},
+		{
+			name: "java not installed",
+			breakIt: func(d *Deps, _ *memObjects) {
+				d.JAR = &fakeJAR{err: errors.New(`exec: "java": executable file not found`)}
+			},
+			want:    map[string]Status{CheckJAR: StatusFail, CheckSample: StatusSkip},
+			message: "could not start",
+		},
+		{
+			name: "JAR rejects --version",
+			breakIt: func(d *Deps, _ *memObjects) {
+				d.JAR = &fakeJAR{out: "Unknown option", err: errors.New("exit status 1")}
+			},
+			want: map[string]Status{CheckJAR: StatusWarn},
+		},
+		{
+			name:    "clerk without a secret in production",
+			breakIt: func(d *Deps, _ *memObjects) { d.Config.AuthProvider, d.Config.LocalAuthSigningKeys = "clerk", nil },
+			want:    map[string]Status{CheckAuth: StatusFail},
+			message: "CLERK_SECRET_KEY is not set",
+		},
+		{
+			name:    "no administrators",
+			breakIt: func(d *Deps, _ *memObjects) { d.Config.AdminUserIDs = nil },
+			want:    map[string]Status{CheckAuth: StatusWarn},
+		},
+		{
+			name:    "sample analysis fails",
+			breakIt: func(d *Deps, _ *memObjects) { d.Sample = &fakeSample{err: errors.New("analyse: job ended failed")} },
+			want:    map[string]Status{CheckSample: StatusFail},
+		},
+		{
+			name: "sample left objects behind",
+			breakIt: func(d *Deps, _ *memObjects) {
+				d.Sample = &fakeSample{err: &cleanupError{err: errors.New("object still stored")}}
+			},
+			want: map[string]Status{CheckSample: StatusWarn},
+		},
+		{
+			name:    "a check panics",
+			breakIt: func(d *Deps, _ *memObjects) { d.Redis = panickingPinger{} },
+			want:    map[string]Status{CheckRedis: StatusFail},
+			message: "check panicked: nil connection",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deps, objects := healthyDeps(t)
+			tc.breakIt(&deps, objects)
+
+			report := New(deps).Run(context.Background(), Options{RunSample: true})
+
+			require.Len(t, report.Checks, 10, "every check reports")
+			for name, status := range statuses(report) {
+				want, ok := tc.want[name]
+				if !ok {
+					want = StatusPass
+				}
+				res := resultOf(t, report, name)
+				assert.Equal(t, want, status, "%s: %s", name, res.Message)
+				if status != StatusPass {
+					assert.NotEmpty(t, res.Remediation, "%s has a remediation", name)
+				}
+			}
+			broken := StatusWarn
+			for _, s := range tc.want {
+				if s == StatusFail {
+					broken = StatusFail
+				}
+			}
+			assert.Equal(t, broken, report.Status)
+			if tc.message != "" {
+				var messages []string
+				for _, c := range report.Checks {
+					messages = append(messages, c.Message)
+				}
+				assert.Contains(t, strings.Join(messages, "\n"), tc.message)
+			}
+			if objects.deleteErr == nil {
+				assert.Empty(t, objects.keys(), "no probe object is left behind")
+			}
+		})
+	}
+}
+
+func TestRun_SchemaRemediationNamesMigrations(t *testing.T) {
+	deps, _ := healthyDeps(t)
+	deps.Postgres = &fakeStore{status: storage.SchemaStatus{Version: 45, Expected: 47, Missing: []int{46, 47}}}
+
+	res := resultOf(t, New(deps).Run(context.Background(), Options{}), CheckPostgresSchema)
+	assert.Contains(t, res.Remediation, "046, 047")
+	assert.Equal(t, []int{46, 47}, res.Detail["missing"])
+}
+
+func TestRunChecks_TimeoutDoesNotBlockOthers(t *testing.T) {
+	b := New(Deps{})
+	var ran []string
+	report := b.runChecks(context.Background(), []check{
+		{name: "hangs", timeout: 20 * time.Millisecond, run: func(ctx context.Context) Result {
+			time.Sleep(time.Second)
+			return pass("too late")
+		}},
+		{name: "needs_hangs", requires: []string{"hangs"}, timeout: time.Second, run: func(ctx context.Context) Result {
+			ran = append(ran, "needs_hangs")
+			return pass("ran")
+		}},
+		{name: "next", timeout: time.Second, run: func(ctx context.Context) Result {
+			ran = append(ran, "next")
+			return pass("ran")
+		}},
+	})
+
+	assert.Equal(t, map[string]Status{"hangs": StatusFail, "needs_hangs": StatusSkip, "next": StatusPass}, statuses(report))
+	assert.Equal(t, []string{"next"}, ran)
+	assert.Contains(t, report.Checks[0].Message, "no answer within 20ms")
+	assert.Less(t, report.DurationMS, int64(500))
+	assert.True(t, report.Failed(false))
+}
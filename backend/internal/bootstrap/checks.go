@@ -0,0 +1,328 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// probePrefix is where the object storage check writes its probe objects.
+const probePrefix = "bootstrap/probes"
+
+// minSigningKeyBytes is the shortest local auth signing key not warned
+// about.
+const minSigningKeyBytes = 32
+
+// checks returns the checks of a run, in order.
+func (b *Bootstrapper) checks(opts Options) []check {
+	checks := []check{
+		{name: CheckPostgres, timeout: DefaultConnectTimeout, run: b.checkPostgres},
+		{name: CheckPostgresSchema, requires: []string{CheckPostgres}, timeout: DefaultCheckTimeout, run: b.checkPostgresSchema},
+		{name: CheckClickHouse, timeout: DefaultConnectTimeout, run: b.checkClickHouse},
+		{name: CheckClickHouseSchema, requires: []string{CheckClickHouse}, timeout: DefaultCheckTimeout, run: b.checkClickHouseSchema},
+		{name: CheckRedis, timeout: DefaultConnectTimeout, run: b.checkRedis},
+		{name: CheckObjectStorage, timeout: DefaultCheckTimeout, run: b.checkObjectStorage},
+		{name: CheckNATS, timeout: DefaultCheckTimeout, run: b.checkNATS},
+		{name: CheckJAR, timeout: DefaultCheckTimeout, run: b.checkJAR},
+		{name: CheckAuth, timeout: DefaultConnectTimeout, run: b.checkAuth},
+	}
+	if opts.RunSample {
+		timeout := opts.SampleTimeout
+		if timeout <= 0 {
+			timeout = DefaultSampleTimeout
+		}
+		checks = append(checks, check{
+			name: CheckSample,
+			requires: []string{CheckPostgres, CheckPostgresSchema, CheckClickHouse, CheckClickHouseSchema,
+				CheckObjectStorage, CheckJAR},
+			timeout: timeout,
+			run:     b.checkSample,
+		})
+	}
+	return checks
+}
+
+func (b *Bootstrapper) checkPostgres(ctx context.Context) Result {
+	const hint = "check POSTGRES_URL and that PostgreSQL accepts connections from this host"
+	if b.deps.Postgres == nil {
+		return b.unavailable(CheckPostgres, hint)
+	}
+	if err := b.deps.Postgres.Ping(ctx); err != nil {
+		return fail(err.Error(), hint)
+	}
+	return pass("connected")
+}
+
+func (b *Bootstrapper) checkPostgresSchema(ctx context.Context) Result {
+	return schemaResult(ctx, b.deps.Postgres, "backend/migrations/*.up.sql (make migrate-up)")
+}
+
+func (b *Bootstrapper) checkClickHouse(ctx context.Context) Result {
+	const hint = "check CLICKHOUSE_URL and that ClickHouse accepts native connections from this host"
+	if b.deps.ClickHouse == nil {
+		return b.unavailable(CheckClickHouse, hint)
+	}
+	if err := b.deps.ClickHouse.Ping(ctx); err != nil {
+		return fail(err.Error(), hint)
+	}
+	return pass("connected")
+}
+
+func (b *Bootstrapper) checkClickHouseSchema(ctx context.Context) Result {
+	return schemaResult(ctx, b.deps.ClickHouse, "backend/migrations/clickhouse/*.sql (make ch-init)")
+}
+
+// schemaResult checks that every migration of a store is applied; scripts
+// names the migrations to apply.
+func schemaResult(ctx context.Context, store SchemaStore, scripts string) Result {
+	status, err := store.SchemaStatus(ctx)
+	if err != nil {
+		return fail(err.Error(), "check that the configured user may read the database catalog")
+	}
+	detail := map[string]any{"version": status.Version, "expected": status.Expected}
+	var res Result
+	switch {
+	case status.Version == 0:
+		res = fail("schema missing", "apply "+scripts+" in order")
+	case !status.Current():
+		detail["missing"] = status.Missing
+		res = fail(fmt.Sprintf("schema at version %d, %d migrations missing", status.Version, len(status.Missing)),
+			fmt.Sprintf("apply migrations %s of %s", joinInts(status.Missing), scripts))
+	default:
+		res = pass(fmt.Sprintf("schema at version %d", status.Version))
+	}
+	res.Detail = detail
+	return res
+}
+
+func (b *Bootstrapper) checkRedis(ctx context.Context) Result {
+	const hint = "check REDIS_URL and that Redis accepts connections from this host"
+	if b.deps.Redis == nil {
+		return b.unavailable(CheckRedis, hint)
+	}
+	if err := b.deps.Redis.Ping(ctx); err != nil {
+		return fail(err.Error(), hint)
+	}
+	return pass("connected")
+}
+
+// bucketChecker is object storage that can tell whether its bucket exists.
+// storage.S3Client implements it.
+type bucketChecker interface {
+	CheckBucket(ctx context.Context) error
+}
+
+// checkObjectStorage writes, reads back and deletes a probe object. The
+// probe is deleted whatever the outcome of the round trip.
+func (b *Bootstrapper) checkObjectStorage(ctx context.Context) (res Result) {
+	const hint = "check STORAGE_BACKEND and its endpoint, bucket and credentials; the credentials need read, write and delete access"
+	objects := b.deps.Objects
+	if objects == nil {
+		return b.unavailable(CheckObjectStorage, hint)
+	}
+	detail := map[string]any{"backend": b.deps.Config.StorageBackend}
+	defer func() { res.Detail = detail }()
+
+	if bc, ok := objects.(bucketChecker); ok {
+		if err := bc.CheckBucket(ctx); err != nil {
+			return fail(err.Error(), "create the bucket "+b.deps.Config.S3Bucket+" or fix S3_BUCKET")
+		}
+		detail["bucket"] = b.deps.Config.S3Bucket
+	}
+
+	key := path.Join(probePrefix, uuid.NewString())
+	content := []byte("remedyiq bootstrap probe " + key)
+	if err := objects.Upload(ctx, key, bytes.NewReader(content), int64(len(content))); err != nil {
+		return fail("write: "+err.Error(), hint)
+	}
+	deleted := false
+	defer func() {
+		if deleted {
+			return
+		}
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultConnectTimeout)
+		defer cancel()
+		if err := objects.Delete(cleanupCtx, key); err != nil {
+			res.Status = StatusFail
+			res.Message += "; probe object not deleted: " + err.Error()
+			res.Remediation = "delete " + key + " by hand; " + hint
+		}
+	}()
+
+	reader, err := objects.Download(ctx, key)
+	if err != nil {
+		return fail("read: "+err.Error(), hint)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fail("read: "+err.Error(), hint)
+	}
+	if !bytes.Equal(got, content) {
+		return fail(fmt.Sprintf("read back %d bytes differing from the %d written", len(got), len(content)),
+			"check that no proxy or gateway in front of the storage rewrites objects")
+	}
+
+	if err := objects.Delete(ctx, key); err != nil {
+		return fail("delete: "+err.Error(), hint)
+	}
+	deleted = true
+	if exists, err := objects.Exists(ctx, key); err != nil || exists {
+		return warn("the deleted probe object is still listed", "check the bucket's versioning or retention settings")
+	}
+	return pass("write, read and delete succeeded")
+}
+
+// checkNATS checks the connection and creates the JetStream streams, as
+// the API and worker do at startup.
+func (b *Bootstrapper) checkNATS(ctx context.Context) Result {
+	const hint = "check NATS_URL and the NATS credentials, and that JetStream is enabled on the server"
+	if b.deps.NATS == nil {
+		return b.unavailable(CheckNATS, hint)
+	}
+	if err := b.deps.NATS.Ping(); err != nil {
+		return fail(err.Error(), hint)
+	}
+	if err := b.deps.NATS.EnsureStreams(ctx); err != nil {
+		return fail("create streams: "+err.Error(), "check that the NATS account may create streams and has JetStream storage left")
+	}
+	return pass("connected, streams JOBS and EVENTS ready")
+}
+
+// checkJAR checks that the JAR is present and starts. A JAR that starts
+// but rejects --version only warns: older builds do not know the flag.
+func (b *Bootstrapper) checkJAR(ctx context.Context) Result {
+	jarPath := b.deps.Config.JARPath
+	detail := map[string]any{"path": jarPath}
+	info, err := os.Stat(jarPath)
+	switch {
+	case jarPath == "":
+		return fail("JAR_PATH is not set", "set JAR_PATH to the path of ARLogAnalyzer.jar")
+	case err != nil:
+		res := fail(err.Error(), "set JAR_PATH to the path of ARLogAnalyzer.jar, readable by this process")
+		res.Detail = detail
+		return res
+	case info.IsDir():
+		res := fail(jarPath+" is a directory", "set JAR_PATH to the JAR file itself")
+		res.Detail = detail
+		return res
+	}
+	if b.deps.JAR == nil {
+		return b.unavailable(CheckJAR, "configure the JAR runner")
+	}
+
+	out, err := b.deps.JAR.Version(ctx)
+	detail["output"] = out
+	var res Result
+	switch {
+	case err != nil && out == "":
+		res = fail("could not start: "+err.Error(), "install a Java runtime and put java on the PATH of this process")
+	case err != nil:
+		res = warn("started, but --version failed: "+err.Error(), "check the output; the JAR may predate --version")
+	default:
+		res = pass(firstLine(out))
+	}
+	res.Detail = detail
+	return res
+}
+
+// checkAuth checks that the auth configuration lets users, and the
+// administrators, sign in.
+func (b *Bootstrapper) checkAuth(ctx context.Context) Result {
+	cfg := b.deps.Config
+	provider := cfg.AuthProvider
+	if provider == "" {
+		provider = "clerk"
+	}
+	detail := map[string]any{"provider": provider, "environment": cfg.Environment}
+	var problems []Result
+
+	switch provider {
+	case "clerk":
+		switch {
+		case cfg.ClerkSecretKey == "" && cfg.IsDevelopment():
+			problems = append(problems, warn("CLERK_SECRET_KEY is not set; only the development headers sign in",
+				"set CLERK_SECRET_KEY, or AUTH_PROVIDER=local for installs without Clerk"))
+		case cfg.ClerkSecretKey == "":
+			problems = append(problems, fail("CLERK_SECRET_KEY is not set; no user can sign in",
+				"set CLERK_SECRET_KEY, or AUTH_PROVIDER=local for installs without Clerk"))
+		case !cfg.IsDevelopment() && strings.HasPrefix(cfg.ClerkSecretKey, "sk_test_"):
+			problems = append(problems, warn("a Clerk test key is used outside development",
+				"use the live secret key of the Clerk production instance"))
+		}
+	case "local":
+		switch {
+		case cfg.ClerkSecretKey != "":
+			problems = append(problems, fail("AUTH_PROVIDER=local is set alongside CLERK_SECRET_KEY", "unset one of them"))
+		case len(cfg.LocalAuthSigningKeys) == 0:
+			problems = append(problems, fail("LOCAL_AUTH_SIGNING_KEYS is not set", "set LOCAL_AUTH_SIGNING_KEYS to a random key"))
+		case len(cfg.LocalAuthSigningKeys[0]) < minSigningKeyBytes:
+			problems = append(problems, warn(fmt.Sprintf("the signing key is shorter than %d bytes", minSigningKeyBytes),
+				"set LOCAL_AUTH_SIGNING_KEYS to a longer random key, keeping the old one after it until tokens expire"))
+		}
+	default:
+		problems = append(problems, fail(fmt.Sprintf("unknown AUTH_PROVIDER %q", cfg.AuthProvider), "set AUTH_PROVIDER to clerk or local"))
+	}
+	if len(cfg.AdminUserIDs) == 0 {
+		problems = append(problems, warn("ADMIN_USER_IDS is empty; no user can reach the admin routes",
+			"set ADMIN_USER_IDS to the IDs of the administrators"))
+	}
+
+	res := pass("configuration is consistent")
+	for _, p := range problems {
+		if res.Status == StatusPass || (res.Status == StatusWarn && p.Status == StatusFail) {
+			res = p
+		}
+	}
+	if len(problems) > 1 {
+		msgs := make([]string, len(problems))
+		for i, p := range problems {
+			msgs[i] = p.Message
+		}
+		detail["problems"] = msgs
+	}
+	res.Detail = detail
+	return res
+}
+
+func (b *Bootstrapper) checkSample(ctx context.Context) Result {
+	if b.deps.Sample == nil {
+		return b.unavailable(CheckSample, "run the bootstrap where the pipeline is configured")
+	}
+	start := time.Now()
+	stages, err := b.deps.Sample.Run(ctx)
+	detail := map[string]any{"stages": stages}
+	var res Result
+	switch {
+	case err == nil:
+		res = pass(fmt.Sprintf("sample analysed and cleaned up in %s", time.Since(start).Round(time.Millisecond)))
+	case IsCleanupError(err):
+		res = warn(err.Error(), "the sample analysed, but delete what is listed by hand")
+	default:
+		res = fail(err.Error(), "see the failed stage; the worker log has the details of the job")
+	}
+	res.Detail = detail
+	return res
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%03d", v)
+	}
+	return strings.Join(parts, ", ")
+}
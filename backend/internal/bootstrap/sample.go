@@ -0,0 +1,281 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// SampleLog is the log the sample analysis runs on: a few API and SQL
+// lines of an AR Server 25 capture.
+//
+//go:embed sample.log
+var SampleLog []byte
+
+const (
+	// SampleTenantOrg identifies the tenant the sample analyses belong to.
+	// The tenant is created by the first run and kept for the next ones;
+	// its analyses are removed after each run.
+	SampleTenantOrg = "remedyiq-bootstrap"
+
+	sampleFilename = "bootstrap_sample.log"
+
+	// sampleCleanupTimeout bounds the removal of a sample analysis, run
+	// even once the analysis has overrun its own timeout.
+	sampleCleanupTimeout = 30 * time.Second
+)
+
+// Stage is one step of the sample analysis.
+type Stage struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Message    string `json:"message,omitempty"`
+}
+
+// Processor runs the ingestion pipeline of a job. worker.Pipeline
+// implements it.
+type Processor interface {
+	ProcessJob(ctx context.Context, job domain.AnalysisJob) error
+}
+
+// Purger permanently deletes an analysis, its entries and its objects.
+// worker.JobPurger implements it.
+type Purger interface {
+	Purge(ctx context.Context, job *domain.AnalysisJob) error
+}
+
+// cleanupError is returned by Sample.Run when the sample analysed but
+// what it created could not all be removed.
+type cleanupError struct{ err error }
+
+func (e *cleanupError) Error() string { return "cleanup: " + e.err.Error() }
+func (e *cleanupError) Unwrap() error { return e.err }
+
+// IsCleanupError reports whether err is only the failure to clean up
+// after a sample analysis that otherwise succeeded.
+func IsCleanupError(err error) bool {
+	var ce *cleanupError
+	return errors.As(err, &ce)
+}
+
+// Sample analyses SampleLog as an upload would be: the file is stored,
+// recorded and analysed by the pipeline, and the entries are read back.
+// Whatever it created is removed before Run returns, whether the analysis
+// succeeded or not.
+type Sample struct {
+	pg       storage.PostgresStore
+	ch       storage.ClickHouseStore
+	objects  storage.ObjectStorage
+	pipeline Processor
+	purger   Purger
+}
+
+// NewSample creates a Sample analysed by pipeline and removed by purger.
+func NewSample(pg storage.PostgresStore, ch storage.ClickHouseStore, objects storage.ObjectStorage, pipeline Processor, purger Purger) *Sample {
+	return &Sample{pg: pg, ch: ch, objects: objects, pipeline: pipeline, purger: purger}
+}
+
+// sampleRun is what a run of the sample created, for its cleanup.
+type sampleRun struct {
+	tenantID uuid.UUID
+	key      string
+	file     *domain.LogFile
+	job      *domain.AnalysisJob
+}
+
+// Run analyses the sample and returns its stages. The error is that of the
+// first stage failing; a cleanup failure after a successful analysis is
+// reported with IsCleanupError.
+func (s *Sample) Run(ctx context.Context) (stages []Stage, err error) {
+	var run sampleRun
+	stage := func(name string, fn func() (string, error)) error {
+		start := time.Now()
+		msg, err := fn()
+		st := Stage{Name: name, Status: StatusPass, DurationMS: time.Since(start).Milliseconds(), Message: msg}
+		if err != nil {
+			st.Status, st.Message = StatusFail, err.Error()
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+		stages = append(stages, st)
+		return err
+	}
+
+	defer func() {
+		cleanupErr := stage("cleanup", func() (string, error) {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sampleCleanupTimeout)
+			defer cancel()
+			return s.cleanup(ctx, &run)
+		})
+		if cleanupErr != nil && err == nil {
+			err = &cleanupError{err: cleanupErr}
+		}
+	}()
+
+	if err := stage("tenant", func() (string, error) {
+		tenant, err := s.tenant(ctx)
+		if err != nil {
+			return "", err
+		}
+		run.tenantID = tenant.ID
+		return "tenant " + tenant.ID.String(), nil
+	}); err != nil {
+		return stages, err
+	}
+	ctx = storage.WithPrimary(storage.WithTenant(ctx, run.tenantID.String()))
+
+	fileID := uuid.New()
+	if err := stage("upload", func() (string, error) {
+		key := path.Join("tenants", run.tenantID.String(), "jobs", fileID.String(), sampleFilename)
+		if err := s.objects.Upload(ctx, key, bytes.NewReader(SampleLog), int64(len(SampleLog))); err != nil {
+			return "", err
+		}
+		run.key = key
+		return fmt.Sprintf("%d bytes stored at %s", len(SampleLog), key), nil
+	}); err != nil {
+		return stages, err
+	}
+
+	if err := stage("submit", func() (string, error) {
+		file := &domain.LogFile{
+			ID:             fileID,
+			TenantID:       run.tenantID,
+			Filename:       sampleFilename,
+			SizeBytes:      int64(len(SampleLog)),
+			S3Key:          run.key,
+			ContentType:    "text/plain",
+			DetectedTypes:  []string{string(domain.LogTypeAPI), string(domain.LogTypeSQL)},
+			ChecksumSHA256: fmt.Sprintf("%x", sha256.Sum256(SampleLog)),
+			SourceType:     domain.LogSourceARServer,
+		}
+		if err := s.pg.CreateLogFile(ctx, file); err != nil {
+			return "", err
+		}
+		run.file = file
+		now := time.Now().UTC()
+		job := &domain.AnalysisJob{
+			ID:        uuid.New(),
+			TenantID:  run.tenantID,
+			FileID:    fileID,
+			Status:    domain.JobStatusQueued,
+			Priority:  domain.JobPriorityNormal,
+			JARFlags:  domain.JARFlags{TopN: 50},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := s.pg.CreateJob(ctx, job); err != nil {
+			return "", err
+		}
+		run.job = job
+		return "job " + job.ID.String(), nil
+	}); err != nil {
+		return stages, err
+	}
+
+	if err := stage("analyse", func() (string, error) {
+		if err := s.pipeline.ProcessJob(ctx, *run.job); err != nil {
+			return "", err
+		}
+		job, err := s.pg.GetJob(ctx, run.tenantID, run.job.ID)
+		if err != nil {
+			return "", err
+		}
+		if job.Status != domain.JobStatusComplete {
+			msg := "job ended " + string(job.Status)
+			if job.ErrorMessage != nil {
+				msg += ": " + *job.ErrorMessage
+			}
+			return "", errors.New(msg)
+		}
+		return "job complete", nil
+	}); err != nil {
+		return stages, err
+	}
+
+	if err := stage("verify", func() (string, error) {
+		count, err := s.ch.CountJobEntries(ctx, run.tenantID.String(), run.job.ID.String())
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "", errors.New("no entries stored in ClickHouse")
+		}
+		return fmt.Sprintf("%d entries stored", count), nil
+	}); err != nil {
+		return stages, err
+	}
+	return stages, nil
+}
+
+// tenant returns the tenant of the sample analyses, creating it on the
+// first run.
+func (s *Sample) tenant(ctx context.Context) (*domain.Tenant, error) {
+	tenant, err := s.pg.GetTenantByClerkOrg(ctx, SampleTenantOrg)
+	if err == nil {
+		return tenant, nil
+	}
+	if !storage.IsNotFound(err) {
+		return nil, err
+	}
+	tenant = &domain.Tenant{ClerkOrgID: SampleTenantOrg, Name: "Bootstrap verification", Plan: "free"}
+	if err := s.pg.CreateTenant(ctx, tenant); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			return s.pg.GetTenantByClerkOrg(ctx, SampleTenantOrg)
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// cleanup removes what run created and checks that it is gone. The job is
+// purged with its entries, file and objects; without a job the file record
+// and the stored file are removed on their own.
+func (s *Sample) cleanup(ctx context.Context, run *sampleRun) (string, error) {
+	var errs []error
+	var removed []string
+	switch {
+	case run.job != nil:
+		if err := s.purger.Purge(ctx, run.job); err != nil {
+			errs = append(errs, fmt.Errorf("purge job %s: %w", run.job.ID, err))
+		} else {
+			removed = append(removed, "job")
+		}
+		if count, err := s.ch.CountJobEntries(ctx, run.tenantID.String(), run.job.ID.String()); err != nil || count > 0 {
+			errs = append(errs, fmt.Errorf("entries of job %s still stored", run.job.ID))
+		}
+	case run.file != nil:
+		if err := s.pg.DeleteLogFile(ctx, run.tenantID, run.file.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete file record %s: %w", run.file.ID, err))
+		} else {
+			removed = append(removed, "file record")
+		}
+	}
+	if run.key != "" {
+		// The purge deletes the stored file too, on a best-effort basis.
+		if run.job == nil {
+			if err := s.objects.Delete(ctx, run.key); err != nil {
+				errs = append(errs, fmt.Errorf("delete object %s: %w", run.key, err))
+			}
+		}
+		if exists, err := s.objects.Exists(ctx, run.key); err != nil || exists {
+			errs = append(errs, fmt.Errorf("object %s still stored", run.key))
+		} else {
+			removed = append(removed, "stored file")
+		}
+	}
+	if len(removed) == 0 && len(errs) == 0 {
+		return "nothing to remove", nil
+	}
+	return "removed " + strings.Join(removed, ", "), errors.Join(errs...)
+}
@@ -0,0 +1,196 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var sampleTenant = &domain.Tenant{ID: uuid.MustParse("00000000-0000-0000-0000-00000000b007"), ClerkOrgID: SampleTenantOrg}
+
+type fakeProcessor struct {
+	pg  *testutil.MockPostgresStore
+	err error
+}
+
+// ProcessJob completes the job, as the pipeline records it, unless it is
+// to fail.
+func (f *fakeProcessor) ProcessJob(ctx context.Context, job domain.AnalysisJob) error {
+	if f.err != nil {
+		return f.err
+	}
+	job.Status = domain.JobStatusComplete
+	f.pg.On("GetJob", mock.Anything, job.TenantID, job.ID).Return(&job, nil)
+	return nil
+}
+
+// fakePurger deletes the stored file of a job, as the purge does.
+type fakePurger struct {
+	objects *memObjects
+	purged  []uuid.UUID
+	err     error
+}
+
+func (f *fakePurger) Purge(ctx context.Context, job *domain.AnalysisJob) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.purged = append(f.purged, job.ID)
+	for _, key := range f.objects.keys() {
+		_ = f.objects.Delete(ctx, key)
+	}
+	return nil
+}
+
+type sampleTest struct {
+	pg      *testutil.MockPostgresStore
+	ch      *testutil.MockClickHouseStore
+	objects *memObjects
+	proc    *fakeProcessor
+	purger  *fakePurger
+	sample  *Sample
+}
+
+func newSampleTest() *sampleTest {
+	tt := &sampleTest{pg: new(testutil.MockPostgresStore), ch: new(testutil.MockClickHouseStore), objects: newMemObjects()}
+	tt.proc = &fakeProcessor{pg: tt.pg}
+	tt.purger = &fakePurger{objects: tt.objects}
+	tt.sample = NewSample(tt.pg, tt.ch, tt.objects, tt.proc, tt.purger)
+	tt.pg.On("GetTenantByClerkOrg", mock.Anything, SampleTenantOrg).Return(sampleTenant, nil).Maybe()
+	return tt
+}
+
+func stageNames(stages []Stage) []string {
+	names := make([]string, len(stages))
+	for i, s := range stages {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func TestSample_Run(t *testing.T) {
+	tt := newSampleTest()
+	var job *domain.AnalysisJob
+	tt.pg.On("CreateLogFile", mock.Anything, mock.MatchedBy(func(f *domain.LogFile) bool {
+		return f.TenantID == sampleTenant.ID && f.SizeBytes == int64(len(SampleLog)) && tt.objects.objects[f.S3Key] != nil
+	})).Return(nil)
+	tt.pg.On("CreateJob", mock.Anything, mock.AnythingOfType("*domain.AnalysisJob")).
+		Run(func(args mock.Arguments) { job = args.Get(1).(*domain.AnalysisJob) }).Return(nil)
+	tt.ch.On("CountJobEntries", mock.Anything, sampleTenant.ID.String(), mock.Anything).Return(int64(5), nil).Once()
+	tt.ch.On("CountJobEntries", mock.Anything, sampleTenant.ID.String(), mock.Anything).Return(int64(0), nil).Once()
+
+	stages, err := tt.sample.Run(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant", "upload", "submit", "analyse", "verify", "cleanup"}, stageNames(stages))
+	for _, s := range stages {
+		assert.Equal(t, StatusPass, s.Status, s.Name)
+	}
+	assert.Equal(t, "5 entries stored", stages[4].Message)
+	require.NotNil(t, job)
+	assert.Equal(t, []uuid.UUID{job.ID}, tt.purger.purged)
+	assert.Empty(t, tt.objects.keys())
+	tt.pg.AssertExpectations(t)
+	tt.ch.AssertExpectations(t)
+}
+
+func TestSample_CreatesTenant(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetTenantByClerkOrg", mock.Anything, SampleTenantOrg).Return(nil, fmt.Errorf("postgres: tenant not found for clerk org: %s", SampleTenantOrg)).Once()
+	pg.On("CreateTenant", mock.Anything, mock.MatchedBy(func(tn *domain.Tenant) bool {
+		return tn.ClerkOrgID == SampleTenantOrg && !tn.Sandbox
+	})).Return(nil)
+
+	tenant, err := NewSample(pg, nil, nil, nil, nil).tenant(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SampleTenantOrg, tenant.ClerkOrgID)
+	pg.AssertExpectations(t)
+}
+
+func TestSample_CleansUpAfterFailure(t *testing.T) {
+	t.Run("analysis fails", func(t *testing.T) {
+		tt := newSampleTest()
+		tt.proc.err = errors.New("jar runner: non-zero exit code 1")
+		tt.pg.On("CreateLogFile", mock.Anything, mock.Anything).Return(nil)
+		tt.pg.On("CreateJob", mock.Anything, mock.Anything).Return(nil)
+		tt.ch.On("CountJobEntries", mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
+
+		stages, err := tt.sample.Run(context.Background())
+
+		require.Error(t, err)
+		assert.False(t, IsCleanupError(err))
+		assert.Contains(t, err.Error(), "analyse: jar runner")
+		assert.Equal(t, []string{"tenant", "upload", "submit", "analyse", "cleanup"}, stageNames(stages))
+		assert.Equal(t, StatusFail, stages[3].Status)
+		assert.Equal(t, StatusPass, stages[4].Status)
+		assert.Len(t, tt.purger.purged, 1)
+		assert.Empty(t, tt.objects.keys())
+	})
+
+	t.Run("job not created", func(t *testing.T) {
+		tt := newSampleTest()
+		tt.pg.On("CreateLogFile", mock.Anything, mock.Anything).Return(nil)
+		tt.pg.On("CreateJob", mock.Anything, mock.Anything).Return(errors.New("postgres: create job: deadlock"))
+		tt.pg.On("DeleteLogFile", mock.Anything, sampleTenant.ID, mock.Anything).Return(nil)
+
+		stages, err := tt.sample.Run(context.Background())
+
+		require.Error(t, err)
+		assert.Equal(t, "removed file record, stored file", stages[len(stages)-1].Message)
+		assert.Empty(t, tt.purger.purged)
+		assert.Empty(t, tt.objects.keys())
+		tt.pg.AssertExpectations(t)
+	})
+
+	t.Run("upload fails", func(t *testing.T) {
+		tt := newSampleTest()
+		tt.objects.uploadErr = errors.New("AccessDenied")
+
+		stages, err := tt.sample.Run(context.Background())
+
+		require.Error(t, err)
+		assert.Equal(t, "nothing to remove", stages[len(stages)-1].Message)
+		tt.pg.AssertNotCalled(t, "CreateLogFile", mock.Anything, mock.Anything)
+	})
+
+	t.Run("purge fails", func(t *testing.T) {
+		tt := newSampleTest()
+		tt.purger.err = errors.New("clickhouse: delete entries: timeout")
+		tt.pg.On("CreateLogFile", mock.Anything, mock.Anything).Return(nil)
+		tt.pg.On("CreateJob", mock.Anything, mock.Anything).Return(nil)
+		tt.ch.On("CountJobEntries", mock.Anything, mock.Anything, mock.Anything).Return(int64(5), nil)
+
+		stages, err := tt.sample.Run(context.Background())
+
+		require.Error(t, err)
+		assert.True(t, IsCleanupError(err), "the analysis itself succeeded")
+		last := stages[len(stages)-1]
+		assert.Equal(t, StatusFail, last.Status)
+		assert.Contains(t, last.Message, "purge job")
+		assert.Contains(t, last.Message, "still stored")
+	})
+}
+
+func TestSample_CleansUpAfterCancellation(t *testing.T) {
+	tt := newSampleTest()
+	ctx, cancel := context.WithCancel(context.Background())
+	tt.proc.err = context.Canceled
+	tt.pg.On("CreateLogFile", mock.Anything, mock.Anything).Return(nil)
+	tt.pg.On("CreateJob", mock.Anything, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(nil)
+	tt.ch.On("CountJobEntries", mock.Anything, mock.Anything, mock.Anything).Return(int64(0), nil)
+
+	_, err := tt.sample.Run(ctx)
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, tt.purger.purged, 1, "the cleanup outlives the cancelled run")
+	assert.Empty(t, tt.objects.keys())
+}
@@ -0,0 +1,221 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when advanced. Its timers and tickers
+// fire, in order of their times, as Advance passes them, and like those of
+// the time package a ticker whose last tick was not received drops the next
+// ones. Stop and Reset drain a timer's channel, as they do from Go 1.23 on.
+//
+// Code under test that waits on a timer in its own goroutine races the
+// test's Advance; BlockUntil lets the test wait for the goroutine to have
+// created its timers first.
+type Fake struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	seq     uint64
+	waiters []*fakeWaiter
+}
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	c := &Fake{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// fakeWaiter is a pending timer or ticker of a Fake.
+type fakeWaiter struct {
+	clock  *Fake
+	c      chan time.Time
+	at     time.Time
+	period time.Duration // zero for timers
+	seq    uint64        // creation order, breaking ties between equal times
+}
+
+// Now returns the time of the clock.
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the time elapsed on the clock since t.
+func (c *Fake) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// After returns the channel of a new timer of d.
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a timer firing once the clock is advanced by d. A timer
+// of zero or less fires at once.
+func (c *Fake) NewTimer(d time.Duration) Timer {
+	w := &fakeWaiter{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(w, d)
+	return (*fakeTimer)(w)
+}
+
+// NewTicker returns a ticker ticking each time the clock is advanced by
+// another d. It panics when d is not positive, as time.NewTicker does.
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{clock: c, c: make(chan time.Time, 1), period: d}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(w, d)
+	return (*fakeTicker)(w)
+}
+
+// Advance moves the clock forward by d, firing the timers and ticking the
+// tickers it passes. Each fires at its own time: Now, read from a receiver
+// of the channel, may already be past it.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		w := c.next()
+		if w == nil || w.at.After(end) {
+			break
+		}
+		c.now = w.at
+		c.fire(w)
+	}
+	c.now = end
+}
+
+// BlockUntil waits until n timers and tickers are pending on the clock.
+func (c *Fake) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
+
+// Pending returns the number of timers and tickers pending on the clock.
+func (c *Fake) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// schedule makes w fire d from now. The caller holds c.mu.
+func (c *Fake) schedule(w *fakeWaiter, d time.Duration) {
+	c.seq++
+	w.seq = c.seq
+	w.at = c.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		send(w.c, c.now)
+		return
+	}
+	c.waiters = append(c.waiters, w)
+	c.changed.Broadcast()
+}
+
+// next returns the waiter due first. The caller holds c.mu.
+func (c *Fake) next() *fakeWaiter {
+	var first *fakeWaiter
+	for _, w := range c.waiters {
+		if first == nil || w.at.Before(first.at) || (w.at.Equal(first.at) && w.seq < first.seq) {
+			first = w
+		}
+	}
+	return first
+}
+
+// fire delivers the time of w, then reschedules a ticker and removes a
+// timer. The caller holds c.mu.
+func (c *Fake) fire(w *fakeWaiter) {
+	send(w.c, w.at)
+	if w.period > 0 {
+		w.at = w.at.Add(w.period)
+		return
+	}
+	c.remove(w)
+}
+
+// remove drops w from the pending waiters and reports whether it was
+// pending. The caller holds c.mu.
+func (c *Fake) remove(w *fakeWaiter) bool {
+	for i, p := range c.waiters {
+		if p == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// send delivers t on ch unless a value is already waiting there.
+func send(ch chan time.Time, t time.Time) {
+	select {
+	case ch <- t:
+	default:
+	}
+}
+
+// drain discards a value waiting on ch.
+func drain(ch chan time.Time) {
+	select {
+	case <-ch:
+	default:
+	}
+}
+
+type fakeTimer fakeWaiter
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drain(t.c)
+	return c.remove((*fakeWaiter)(t))
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drain(t.c)
+	pending := c.remove((*fakeWaiter)(t))
+	c.schedule((*fakeWaiter)(t), d)
+	return pending
+}
+
+type fakeTicker fakeWaiter
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove((*fakeWaiter)(t))
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove((*fakeWaiter)(t))
+	t.period = d
+	c.schedule((*fakeWaiter)(t), d)
+}
@@ -0,0 +1,154 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fired returns the time waiting on ch, if any.
+func fired(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFake_NowMovesOnlyWhenAdvanced(t *testing.T) {
+	c := NewFake(start)
+	assert.Equal(t, start, c.Now())
+	c.Advance(90 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), c.Now())
+	assert.Equal(t, 30*time.Second, c.Since(start.Add(time.Minute)))
+}
+
+func TestFake_Timer(t *testing.T) {
+	c := NewFake(start)
+	timer := c.NewTimer(time.Second)
+
+	c.Advance(999 * time.Millisecond)
+	_, ok := fired(timer.C())
+	assert.False(t, ok, "not due yet")
+
+	c.Advance(5 * time.Millisecond)
+	at, ok := fired(timer.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(time.Second), at, "fires at its own time")
+	assert.Zero(t, c.Pending())
+
+	c.Advance(time.Hour)
+	_, ok = fired(timer.C())
+	assert.False(t, ok, "a timer fires once")
+	assert.False(t, timer.Stop(), "stopping a fired timer")
+}
+
+func TestFake_TimerStopAndReset(t *testing.T) {
+	c := NewFake(start)
+	timer := c.NewTimer(time.Second)
+	assert.True(t, timer.Stop())
+	c.Advance(time.Minute)
+	_, ok := fired(timer.C())
+	assert.False(t, ok, "a stopped timer does not fire")
+
+	assert.False(t, timer.Reset(time.Second), "the timer was not pending")
+	c.Advance(time.Second)
+	_, ok = fired(timer.C())
+	assert.True(t, ok)
+
+	// Reset drains a fired value nobody received.
+	timer.Reset(time.Millisecond)
+	c.Advance(time.Millisecond)
+	assert.False(t, timer.Reset(time.Second))
+	_, ok = fired(timer.C())
+	assert.False(t, ok, "the stale value was drained")
+	c.Advance(time.Second)
+	at, ok := fired(timer.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(time.Minute+2*time.Second+time.Millisecond), at)
+}
+
+func TestFake_ZeroTimerFiresAtOnce(t *testing.T) {
+	c := NewFake(start)
+	at, ok := fired(c.After(0))
+	require.True(t, ok)
+	assert.Equal(t, start, at)
+}
+
+func TestFake_TimersFireInOrder(t *testing.T) {
+	c := NewFake(start)
+	late := c.NewTimer(3 * time.Second)
+	early := c.NewTimer(time.Second)
+	tick := c.NewTicker(2 * time.Second)
+	c.Advance(3 * time.Second)
+
+	at, ok := fired(early.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(time.Second), at)
+	at, ok = fired(tick.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(2*time.Second), at)
+	at, ok = fired(late.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(3*time.Second), at)
+}
+
+func TestFake_Ticker(t *testing.T) {
+	c := NewFake(start)
+	tick := c.NewTicker(time.Second)
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		at, ok := fired(tick.C())
+		require.True(t, ok, "tick %d", i)
+		assert.Equal(t, start.Add(time.Duration(i)*time.Second), at)
+	}
+
+	// Ticks nobody receives are dropped, not queued.
+	c.Advance(5 * time.Second)
+	at, ok := fired(tick.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(4*time.Second), at)
+	_, ok = fired(tick.C())
+	assert.False(t, ok)
+
+	tick.Reset(10 * time.Second)
+	c.Advance(9 * time.Second)
+	_, ok = fired(tick.C())
+	assert.False(t, ok, "the reset period applies")
+	c.Advance(time.Second)
+	_, ok = fired(tick.C())
+	assert.True(t, ok)
+
+	tick.Stop()
+	assert.Zero(t, c.Pending())
+	c.Advance(time.Minute)
+	_, ok = fired(tick.C())
+	assert.False(t, ok, "a stopped ticker does not tick")
+
+	assert.Panics(t, func() { c.NewTicker(0) })
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	c := NewFake(start)
+	got := make(chan time.Time)
+	go func() {
+		got <- <-c.After(time.Minute)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), <-got)
+}
+
+func TestSequence(t *testing.T) {
+	s := NewSequence()
+	assert.Equal(t, "00000000-0000-0000-0000-000000000001", s.New().String())
+	assert.Equal(t, "00000000-0000-0000-0000-000000000002", s.New().String())
+	assert.NotEqual(t, RandomIDs.New(), RandomIDs.New())
+}
@@ -0,0 +1,43 @@
+package clock
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator makes the IDs of new records.
+type IDGenerator interface {
+	New() uuid.UUID
+}
+
+// RandomIDs makes random (version 4) UUIDs.
+var RandomIDs IDGenerator = randomIDs{}
+
+type randomIDs struct{}
+
+func (randomIDs) New() uuid.UUID { return uuid.New() }
+
+// Sequence makes predictable IDs for tests: the first is
+// 00000000-0000-0000-0000-000000000001, the next ...0002 and so on.
+type Sequence struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+// NewSequence returns a Sequence starting at 1.
+func NewSequence() *Sequence {
+	return &Sequence{}
+}
+
+// New returns the next ID of the sequence.
+func (s *Sequence) New() uuid.UUID {
+	s.mu.Lock()
+	s.n++
+	n := s.n
+	s.mu.Unlock()
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[8:], n)
+	return id
+}
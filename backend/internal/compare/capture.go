@@ -0,0 +1,160 @@
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// captureDurationRatio is how many times longer or shorter a capture window
+// has to be than the reference one for the captures not to be comparable.
+const captureDurationRatio = 2
+
+// ProfileOf returns how job's capture was taken: the profile recorded when
+// it completed or, for analyses completed before profiles were recorded,
+// one made from the job's counts and log range, with the file count
+// unknown. It is nil for a nil job.
+func ProfileOf(job *domain.AnalysisJob) *domain.CaptureProfile {
+	if job == nil {
+		return nil
+	}
+	if job.CaptureProfile != nil {
+		return job.CaptureProfile
+	}
+	p := &domain.CaptureProfile{JARFlags: job.JARFlags}
+	if s := job.Sections; s != nil {
+		p.API, p.SQL, p.Filter, p.Escalation = s.API.Present, s.SQL.Present, s.Filter.Present, s.Escalation.Present
+	} else {
+		positive := func(n *int64) bool { return n != nil && *n > 0 }
+		p.API, p.SQL, p.Filter, p.Escalation = positive(job.APICount), positive(job.SQLCount), positive(job.FilterCount), positive(job.EscCount)
+	}
+	if job.LogStart != nil && job.LogEnd != nil && job.LogEnd.After(*job.LogStart) {
+		p.DurationMS = job.LogEnd.Sub(*job.LogStart).Milliseconds()
+	}
+	return p
+}
+
+// captureFlag is a JAR flag that changes which entries an analysis holds.
+// Flags that only change how the report is laid out or parsed are left out.
+type captureFlag struct {
+	name  string
+	value func(domain.JARFlags) string
+}
+
+var captureFlags = []captureFlag{
+	{"skip_api", func(f domain.JARFlags) string { return strconv.FormatBool(f.SkipAPI) }},
+	{"skip_sql", func(f domain.JARFlags) string { return strconv.FormatBool(f.SkipSQL) }},
+	{"skip_fltr", func(f domain.JARFlags) string { return strconv.FormatBool(f.SkipFltr) }},
+	{"skip_esc", func(f domain.JARFlags) string { return strconv.FormatBool(f.SkipEsc) }},
+	{"include_fts", func(f domain.JARFlags) string { return strconv.FormatBool(f.IncludeFTS) }},
+	{"top_n", func(f domain.JARFlags) string { return strconv.Itoa(f.TopN) }},
+	{"group_by", func(f domain.JARFlags) string { return strings.Join(f.GroupBy, ",") }},
+	{"user_filter", func(f domain.JARFlags) string { return f.UserFilter }},
+	{"exclude_users", func(f domain.JARFlags) string { return sortedList(f.ExcludeUsers) }},
+	{"begin_time", func(f domain.JARFlags) string { return f.BeginTime }},
+	{"end_time", func(f domain.JARFlags) string { return f.EndTime }},
+}
+
+func sortedList(values []string) string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return strings.Join(values, ",")
+}
+
+// CaptureDrift returns how the capture described by current differs from
+// the reference capture: JAR flags changing the analysed entries, log types
+// logged in one capture only, a capture window at least twice or half as
+// long, and a different number of captured files. Unknown durations and
+// file counts are not compared. There is nothing to compare without a
+// reference capture. The messages name the reference capture as against,
+// such as "previous capture".
+func CaptureDrift(reference, current *domain.CaptureProfile, against string) []domain.CaptureDriftWarning {
+	if reference == nil || current == nil {
+		return nil
+	}
+	var warnings []domain.CaptureDriftWarning
+
+	for _, flag := range captureFlags {
+		prev, cur := flag.value(reference.JARFlags), flag.value(current.JARFlags)
+		if prev == cur {
+			continue
+		}
+		warnings = append(warnings, domain.CaptureDriftWarning{
+			Kind: domain.CaptureDriftJARFlags, Field: flag.name, Previous: prev, Current: cur,
+			Message: fmt.Sprintf("JAR flag %s is %s in this capture but %s in the %s", flag.name, describeFlag(cur), describeFlag(prev), against),
+		})
+	}
+
+	for _, lt := range []struct {
+		field, name string
+		prev, cur   bool
+	}{
+		{"api", "API", reference.API, current.API},
+		{"sql", "SQL", reference.SQL, current.SQL},
+		{"filter", "Filter", reference.Filter, current.Filter},
+		{"escalation", "Escalation", reference.Escalation, current.Escalation},
+	} {
+		if lt.prev == lt.cur {
+			continue
+		}
+		message := lt.name + " logging absent in this capture but present in the " + against
+		if lt.cur {
+			message = lt.name + " logging present in this capture but absent in the " + against
+		}
+		warnings = append(warnings, domain.CaptureDriftWarning{
+			Kind: domain.CaptureDriftLogType, Field: lt.field,
+			Previous: presence(lt.prev), Current: presence(lt.cur), Message: message,
+		})
+	}
+
+	if prev, cur := reference.DurationMS, current.DurationMS; prev > 0 && cur > 0 &&
+		(cur >= prev*captureDurationRatio || prev >= cur*captureDurationRatio) {
+		p, c := captureWindow(prev), captureWindow(cur)
+		warnings = append(warnings, domain.CaptureDriftWarning{
+			Kind: domain.CaptureDriftDuration, Field: "duration", Previous: p, Current: c,
+			Message: fmt.Sprintf("capture window %s vs %s in the %s", c, p, against),
+		})
+	}
+
+	if prev, cur := reference.FileCount, current.FileCount; prev > 0 && cur > 0 && prev != cur {
+		warnings = append(warnings, domain.CaptureDriftWarning{
+			Kind: domain.CaptureDriftFileCount, Field: "file_count",
+			Previous: strconv.Itoa(prev), Current: strconv.Itoa(cur),
+			Message: fmt.Sprintf("%d files captured vs %d in the %s", cur, prev, against),
+		})
+	}
+	return warnings
+}
+
+func describeFlag(value string) string {
+	if value == "" {
+		return "unset"
+	}
+	return value
+}
+
+func presence(present bool) string {
+	if present {
+		return "present"
+	}
+	return "absent"
+}
+
+// captureWindow formats a capture window to the minute: "2h", "8h30m",
+// "45m". Windows under a minute are given in seconds.
+func captureWindow(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := d.Round(time.Minute).String()
+	s = strings.TrimSuffix(s, "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
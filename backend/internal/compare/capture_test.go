@@ -0,0 +1,204 @@
+package compare
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// fullCapture is a one hour capture of two files logging every log type.
+func fullCapture() *domain.CaptureProfile {
+	return &domain.CaptureProfile{
+		JARFlags:   domain.JARFlags{TopN: 50},
+		API:        true,
+		SQL:        true,
+		Filter:     true,
+		Escalation: true,
+		DurationMS: time.Hour.Milliseconds(),
+		FileCount:  2,
+	}
+}
+
+func TestCaptureDrift(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference func() *domain.CaptureProfile
+		current   func(p *domain.CaptureProfile)
+		want      []domain.CaptureDriftWarning
+	}{
+		{
+			name:    "consistent captures",
+			current: func(p *domain.CaptureProfile) {},
+		},
+		{
+			name:      "no previous analysis",
+			reference: func() *domain.CaptureProfile { return nil },
+			current:   func(p *domain.CaptureProfile) { p.SQL = false },
+		},
+		{
+			name:    "jar flag changed",
+			current: func(p *domain.CaptureProfile) { p.JARFlags.SkipSQL = true },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftJARFlags, Field: "skip_sql", Previous: "false", Current: "true",
+				Message: "JAR flag skip_sql is true in this capture but false in the previous capture",
+			}},
+		},
+		{
+			name:    "jar flag set",
+			current: func(p *domain.CaptureProfile) { p.JARFlags.UserFilter = "Demo" },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftJARFlags, Field: "user_filter", Previous: "", Current: "Demo",
+				Message: "JAR flag user_filter is Demo in this capture but unset in the previous capture",
+			}},
+		},
+		{
+			name:    "excluded users in another order",
+			current: func(p *domain.CaptureProfile) { p.JARFlags.ExcludeUsers = []string{"b", "a"} },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftJARFlags, Field: "exclude_users", Previous: "", Current: "a,b",
+				Message: "JAR flag exclude_users is a,b in this capture but unset in the previous capture",
+			}},
+		},
+		{
+			name:    "log type absent",
+			current: func(p *domain.CaptureProfile) { p.SQL = false },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftLogType, Field: "sql", Previous: "present", Current: "absent",
+				Message: "SQL logging absent in this capture but present in the previous capture",
+			}},
+		},
+		{
+			name: "log type present",
+			reference: func() *domain.CaptureProfile {
+				p := fullCapture()
+				p.Escalation = false
+				return p
+			},
+			current: func(p *domain.CaptureProfile) {},
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftLogType, Field: "escalation", Previous: "absent", Current: "present",
+				Message: "Escalation logging present in this capture but absent in the previous capture",
+			}},
+		},
+		{
+			name:    "capture window much longer",
+			current: func(p *domain.CaptureProfile) { p.DurationMS = (8*time.Hour + 30*time.Minute).Milliseconds() },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftDuration, Field: "duration", Previous: "1h", Current: "8h30m",
+				Message: "capture window 8h30m vs 1h in the previous capture",
+			}},
+		},
+		{
+			name:    "capture window much shorter",
+			current: func(p *domain.CaptureProfile) { p.DurationMS = (25 * time.Minute).Milliseconds() },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftDuration, Field: "duration", Previous: "1h", Current: "25m",
+				Message: "capture window 25m vs 1h in the previous capture",
+			}},
+		},
+		{
+			name:    "capture window a little longer",
+			current: func(p *domain.CaptureProfile) { p.DurationMS = (90 * time.Minute).Milliseconds() },
+		},
+		{
+			name:    "unknown capture window",
+			current: func(p *domain.CaptureProfile) { p.DurationMS = 0 },
+		},
+		{
+			name:    "file count changed",
+			current: func(p *domain.CaptureProfile) { p.FileCount = 5 },
+			want: []domain.CaptureDriftWarning{{
+				Kind: domain.CaptureDriftFileCount, Field: "file_count", Previous: "2", Current: "5",
+				Message: "5 files captured vs 2 in the previous capture",
+			}},
+		},
+		{
+			name:    "unknown file count",
+			current: func(p *domain.CaptureProfile) { p.FileCount = 0 },
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reference := fullCapture()
+			if tc.reference != nil {
+				reference = tc.reference()
+			}
+			current := fullCapture()
+			tc.current(current)
+
+			assert.Equal(t, tc.want, CaptureDrift(reference, current, "previous capture"))
+		})
+	}
+}
+
+func TestCaptureDrift_SeveralKinds(t *testing.T) {
+	current := fullCapture()
+	current.JARFlags.SkipAPI = true
+	current.API = false
+	current.FileCount = 1
+
+	drift := CaptureDrift(fullCapture(), current, "baseline capture")
+	require.Len(t, drift, 3)
+	assert.Equal(t, domain.CaptureDriftJARFlags, drift[0].Kind)
+	assert.Equal(t, domain.CaptureDriftLogType, drift[1].Kind)
+	assert.Equal(t, domain.CaptureDriftFileCount, drift[2].Kind)
+	assert.Equal(t, "1 files captured vs 2 in the baseline capture", drift[2].Message)
+}
+
+func TestProfileOf(t *testing.T) {
+	start := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	count := func(n int64) *int64 { return &n }
+
+	t.Run("recorded profile", func(t *testing.T) {
+		p := fullCapture()
+		assert.Same(t, p, ProfileOf(&domain.AnalysisJob{CaptureProfile: p}))
+	})
+
+	t.Run("legacy job from counts", func(t *testing.T) {
+		job := &domain.AnalysisJob{
+			JARFlags: domain.JARFlags{TopN: 50},
+			APICount: count(10), SQLCount: count(0), FilterCount: count(3),
+			LogStart: &start, LogEnd: &end,
+		}
+		assert.Equal(t, &domain.CaptureProfile{
+			JARFlags: domain.JARFlags{TopN: 50}, API: true, Filter: true,
+			DurationMS: (2 * time.Hour).Milliseconds(),
+		}, ProfileOf(job))
+	})
+
+	t.Run("legacy job from section presence", func(t *testing.T) {
+		job := &domain.AnalysisJob{
+			APICount: count(10),
+			Sections: &domain.SectionPresence{SQL: domain.LogTypePresence{Present: true}},
+		}
+		p := ProfileOf(job)
+		assert.False(t, p.API)
+		assert.True(t, p.SQL)
+	})
+
+	t.Run("nil job", func(t *testing.T) {
+		assert.Nil(t, ProfileOf(nil))
+	})
+}
+
+func TestCaptureWindow(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{2 * time.Hour, "2h"},
+		{8*time.Hour + 30*time.Minute, "8h30m"},
+		{45 * time.Minute, "45m"},
+		{44*time.Minute + 50*time.Second, "45m"},
+		{30 * time.Second, "30s"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, captureWindow(tc.d.Milliseconds()), tc.d.String())
+	}
+}
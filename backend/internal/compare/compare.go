@@ -0,0 +1,389 @@
+// Package compare computes before/after comparisons of two analyses. It
+// backs the comparison endpoint, comparison exports and the daily digest.
+package compare
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// TopFormRegressions is the number of form regressions in a comparison.
+	TopFormRegressions = 20
+
+	// exceptionRateChangeThreshold is the relative change in hourly rate at
+	// which an error code seen in both analyses is reported.
+	exceptionRateChangeThreshold = 0.25
+
+	// dashboardTopN is the top-N size requested when only the general
+	// statistics of the dashboard are needed.
+	dashboardTopN = 1
+)
+
+// ParseSections validates section names and returns them in report order.
+// No names selects every section.
+func ParseSections(names []string) ([]domain.ComparisonSection, error) {
+	if len(names) == 0 {
+		return domain.ComparisonSections, nil
+	}
+	want := make(map[domain.ComparisonSection]bool, len(names))
+	for _, n := range names {
+		s := domain.ComparisonSection(n)
+		if !hasSection(domain.ComparisonSections, s) {
+			return nil, fmt.Errorf("unknown section %q", n)
+		}
+		want[s] = true
+	}
+	sections := make([]domain.ComparisonSection, 0, len(want))
+	for _, s := range domain.ComparisonSections {
+		if want[s] {
+			sections = append(sections, s)
+		}
+	}
+	return sections, nil
+}
+
+func hasSection(sections []domain.ComparisonSection, s domain.ComparisonSection) bool {
+	for _, x := range sections {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Comparer collects the data of analysis comparisons from existing stores.
+type Comparer struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+// NewComparer creates a Comparer.
+func NewComparer(pg storage.PostgresStore, ch storage.ClickHouseStore) *Comparer {
+	return &Comparer{pg: pg, ch: ch}
+}
+
+// Compare builds the comparison of candidate against baseline for the given
+// sections. Both jobs must belong to tenantID and be complete.
+func (c *Comparer) Compare(ctx context.Context, tenantID uuid.UUID, baseline, candidate *domain.AnalysisJob, sections []domain.ComparisonSection) (*domain.AnalysisComparison, error) {
+	cmp := &domain.AnalysisComparison{
+		Baseline:    c.describe(ctx, tenantID, baseline),
+		Candidate:   c.describe(ctx, tenantID, candidate),
+		Sections:    sections,
+		GeneratedAt: domain.NewTimestamp(time.Now().UTC()),
+
+		CaptureDrift: CaptureDrift(ProfileOf(baseline), ProfileOf(candidate), "baseline capture"),
+	}
+	tid := tenantID.String()
+	bid, cid := baseline.ID.String(), candidate.ID.String()
+
+	var baseStats, candStats domain.GeneralStatistics
+	if hasSection(sections, domain.ComparisonGeneral) || hasSection(sections, domain.ComparisonExceptions) {
+		b, err := c.ch.GetDashboardData(ctx, tid, bid, dashboardTopN)
+		if err != nil {
+			return nil, fmt.Errorf("baseline statistics: %w", err)
+		}
+		cd, err := c.ch.GetDashboardData(ctx, tid, cid, dashboardTopN)
+		if err != nil {
+			return nil, fmt.Errorf("candidate statistics: %w", err)
+		}
+		baseStats, candStats = b.GeneralStats, cd.GeneralStats
+	}
+
+	for _, s := range sections {
+		switch s {
+		case domain.ComparisonGeneral:
+			cmp.Stats = DiffStats(baseStats, candStats)
+
+		case domain.ComparisonForms:
+			b, err := c.ch.GetAggregates(ctx, tid, bid)
+			if err != nil {
+				return nil, fmt.Errorf("baseline aggregates: %w", err)
+			}
+			cd, err := c.ch.GetAggregates(ctx, tid, cid)
+			if err != nil {
+				return nil, fmt.Errorf("candidate aggregates: %w", err)
+			}
+			cmp.Forms = Regressions(DiffForms(b.API, cd.API), TopFormRegressions)
+
+		case domain.ComparisonExceptions:
+			b, err := c.ch.GetExceptions(ctx, tid, bid)
+			if err != nil {
+				return nil, fmt.Errorf("baseline exceptions: %w", err)
+			}
+			cd, err := c.ch.GetExceptions(ctx, tid, cid)
+			if err != nil {
+				return nil, fmt.Errorf("candidate exceptions: %w", err)
+			}
+			cmp.Exceptions = DiffExceptions(b.Exceptions, cd.Exceptions, LogHours(baseStats), LogHours(candStats))
+
+		case domain.ComparisonHealth:
+			profile, err := storage.LoadHealthProfile(ctx, c.pg, tenantID)
+			if err != nil {
+				slog.Warn("compare: health profile unavailable, using default", "tenant_id", tid, "error", err)
+				profile = nil
+			}
+			b, err := c.ch.ComputeHealthScore(ctx, tid, bid, profile, baseline.Restarts)
+			if err != nil {
+				return nil, fmt.Errorf("baseline health score: %w", err)
+			}
+			cd, err := c.ch.ComputeHealthScore(ctx, tid, cid, profile, candidate.Restarts)
+			if err != nil {
+				return nil, fmt.Errorf("candidate health score: %w", err)
+			}
+			cmp.Health = DiffHealth(b, cd)
+
+		case domain.ComparisonQueues:
+			b, err := c.ch.GetGaps(ctx, tid, bid)
+			if err != nil {
+				return nil, fmt.Errorf("baseline queue health: %w", err)
+			}
+			cd, err := c.ch.GetGaps(ctx, tid, cid)
+			if err != nil {
+				return nil, fmt.Errorf("candidate queue health: %w", err)
+			}
+			cmp.Queues = DiffQueues(b.QueueHealth, cd.QueueHealth)
+		}
+	}
+	return cmp, nil
+}
+
+// describe labels one side of a comparison; the filename is best effort.
+func (c *Comparer) describe(ctx context.Context, tenantID uuid.UUID, job *domain.AnalysisJob) domain.ComparedAnalysis {
+	a := domain.ComparedAnalysis{JobID: job.ID, CompletedAt: domain.TimestampPtr(job.CompletedAt)}
+	if f, err := c.pg.GetLogFile(ctx, tenantID, job.FileID); err == nil {
+		a.Filename = f.Filename
+	}
+	return a
+}
+
+// DiffStats compares the general statistics of two analyses.
+func DiffStats(base, cand domain.GeneralStatistics) []domain.StatComparison {
+	rows := []struct {
+		name       string
+		base, cand float64
+	}{
+		{"Total lines", float64(base.TotalLines), float64(cand.TotalLines)},
+		{"API calls", float64(base.APICount), float64(cand.APICount)},
+		{"SQL statements", float64(base.SQLCount), float64(cand.SQLCount)},
+		{"Filters", float64(base.FilterCount), float64(cand.FilterCount)},
+		{"Escalations", float64(base.EscCount), float64(cand.EscCount)},
+		{"Unique users", float64(base.UniqueUsers), float64(cand.UniqueUsers)},
+		{"Unique forms", float64(base.UniqueForms), float64(cand.UniqueForms)},
+		{"Unique tables", float64(base.UniqueTables), float64(cand.UniqueTables)},
+		{"Log duration (h)", LogHours(base), LogHours(cand)},
+	}
+	out := make([]domain.StatComparison, len(rows))
+	for i, r := range rows {
+		out[i] = domain.StatComparison{Name: r.name, Baseline: r.base, Candidate: r.cand, Delta: r.cand - r.base}
+	}
+	return out
+}
+
+// LogHours returns the time span covered by a log in hours.
+func LogHours(s domain.GeneralStatistics) float64 {
+	if s.LogEnd.Before(s.LogStart.Time) {
+		return 0
+	}
+	return s.LogEnd.Sub(s.LogStart.Time).Hours()
+}
+
+// DiffForms pairs every form of the candidate with its baseline figures,
+// in candidate order. base may be nil when the baseline has no API data.
+func DiffForms(base, cand *domain.AggregateSection) []domain.FormComparison {
+	if cand == nil {
+		return nil
+	}
+	prev := make(map[string]domain.AggregateGroup)
+	if base != nil {
+		for _, g := range base.Groups {
+			prev[g.Name] = g
+		}
+	}
+
+	forms := make([]domain.FormComparison, 0, len(cand.Groups))
+	for _, g := range cand.Groups {
+		f := domain.FormComparison{Form: g.Name, CandidateCount: g.Count, CandidateAvgMS: g.AvgMS}
+		if p, ok := prev[g.Name]; ok {
+			avg := p.AvgMS
+			f.BaselineCount = p.Count
+			f.BaselineAvgMS = &avg
+			f.DeltaMS = g.AvgMS - avg
+		}
+		forms = append(forms, f)
+	}
+	return forms
+}
+
+// Regressions returns up to n forms seen in both analyses whose average
+// latency increased, largest increase first.
+func Regressions(forms []domain.FormComparison, n int) []domain.FormComparison {
+	var out []domain.FormComparison
+	for _, f := range forms {
+		if f.BaselineAvgMS != nil && f.DeltaMS > 0 {
+			out = append(out, f)
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].DeltaMS > out[b].DeltaMS })
+	return out[:min(len(out), n)]
+}
+
+// exceptionTotal is one error code summed over its exception entries.
+type exceptionTotal struct {
+	message string
+	count   int64
+}
+
+// totalExceptions sums entries per error code, returning the codes in order
+// of first appearance.
+func totalExceptions(entries []domain.ExceptionEntry) ([]string, map[string]*exceptionTotal) {
+	var codes []string
+	totals := make(map[string]*exceptionTotal)
+	for _, e := range entries {
+		t, ok := totals[e.ErrorCode]
+		if !ok {
+			t = &exceptionTotal{message: e.Message}
+			totals[e.ErrorCode] = t
+			codes = append(codes, e.ErrorCode)
+		}
+		t.count += e.Count
+	}
+	return codes, totals
+}
+
+// perHour converts a count over a log of the given length into an hourly
+// rate; logs without a measurable span report the raw count.
+func perHour(count int64, hours float64) float64 {
+	if hours <= 0 {
+		return float64(count)
+	}
+	return float64(count) / hours
+}
+
+// DiffExceptions compares the error codes of two analyses. Codes only in
+// the candidate are reported as appeared, codes only in the baseline as
+// disappeared, and codes in both whose hourly rate moved by at least 25% as
+// rate changes. Appeared codes come first, most frequent first, then rate
+// changes by size of the change, then disappeared codes.
+func DiffExceptions(base, cand []domain.ExceptionEntry, baseHours, candHours float64) []domain.ExceptionComparison {
+	baseCodes, baseTotals := totalExceptions(base)
+	candCodes, candTotals := totalExceptions(cand)
+
+	var appeared, changed, disappeared []domain.ExceptionComparison
+	for _, code := range candCodes {
+		ct := candTotals[code]
+		ec := domain.ExceptionComparison{
+			ErrorCode:        code,
+			Message:          ct.message,
+			CandidateCount:   ct.count,
+			CandidatePerHour: perHour(ct.count, candHours),
+		}
+		bt, ok := baseTotals[code]
+		if !ok {
+			ec.Change = domain.ExceptionAppeared
+			appeared = append(appeared, ec)
+			continue
+		}
+		ec.BaselineCount = bt.count
+		ec.BaselinePerHour = perHour(bt.count, baseHours)
+		if ec.BaselinePerHour > 0 && math.Abs(ec.CandidatePerHour-ec.BaselinePerHour)/ec.BaselinePerHour >= exceptionRateChangeThreshold {
+			ec.Change = domain.ExceptionRateChanged
+			changed = append(changed, ec)
+		}
+	}
+	for _, code := range baseCodes {
+		if _, ok := candTotals[code]; ok {
+			continue
+		}
+		bt := baseTotals[code]
+		disappeared = append(disappeared, domain.ExceptionComparison{
+			ErrorCode:       code,
+			Message:         bt.message,
+			Change:          domain.ExceptionDisappeared,
+			BaselineCount:   bt.count,
+			BaselinePerHour: perHour(bt.count, baseHours),
+		})
+	}
+
+	sort.SliceStable(appeared, func(a, b int) bool { return appeared[a].CandidateCount > appeared[b].CandidateCount })
+	sort.SliceStable(changed, func(a, b int) bool {
+		return math.Abs(changed[a].CandidatePerHour-changed[a].BaselinePerHour) >
+			math.Abs(changed[b].CandidatePerHour-changed[b].BaselinePerHour)
+	})
+	sort.SliceStable(disappeared, func(a, b int) bool { return disappeared[a].BaselineCount > disappeared[b].BaselineCount })
+
+	out := make([]domain.ExceptionComparison, 0, len(appeared)+len(changed)+len(disappeared))
+	out = append(out, appeared...)
+	out = append(out, changed...)
+	return append(out, disappeared...)
+}
+
+// DiffHealth pairs the factors of two health scores by name, in candidate
+// order followed by factors only the baseline scored.
+func DiffHealth(base, cand *domain.HealthScore) *domain.HealthComparison {
+	hc := &domain.HealthComparison{Baseline: base, Candidate: cand, Factors: []domain.HealthFactorComparison{}}
+	index := make(map[string]int)
+	add := func(f domain.HealthScoreFactor, baseline bool) {
+		i, ok := index[f.Name]
+		if !ok {
+			i = len(hc.Factors)
+			index[f.Name] = i
+			hc.Factors = append(hc.Factors, domain.HealthFactorComparison{Name: f.Name})
+		}
+		score := f.Score
+		if baseline {
+			hc.Factors[i].Baseline = &score
+		} else {
+			hc.Factors[i].Candidate = &score
+		}
+	}
+	if cand != nil {
+		for _, f := range cand.Factors {
+			add(f, false)
+		}
+	}
+	if base != nil {
+		for _, f := range base.Factors {
+			add(f, true)
+		}
+	}
+	return hc
+}
+
+// DiffQueues pairs the queue health of two analyses by queue, busiest
+// candidate queues first, followed by queues only active in the baseline.
+func DiffQueues(base, cand []domain.QueueHealthSummary) []domain.QueueComparison {
+	prev := make(map[string]*domain.QueueHealthSummary, len(base))
+	for i := range base {
+		prev[base[i].Queue] = &base[i]
+	}
+
+	sortedCand := append([]domain.QueueHealthSummary(nil), cand...)
+	sort.SliceStable(sortedCand, func(a, b int) bool { return sortedCand[a].TotalCalls > sortedCand[b].TotalCalls })
+
+	out := make([]domain.QueueComparison, 0, len(base)+len(cand))
+	seen := make(map[string]bool, len(cand))
+	for i := range sortedCand {
+		q := &sortedCand[i]
+		seen[q.Queue] = true
+		out = append(out, domain.QueueComparison{Queue: q.Queue, Baseline: prev[q.Queue], Candidate: q})
+	}
+
+	var gone []domain.QueueComparison
+	for i := range base {
+		if !seen[base[i].Queue] {
+			gone = append(gone, domain.QueueComparison{Queue: base[i].Queue, Baseline: &base[i]})
+		}
+	}
+	sort.SliceStable(gone, func(a, b int) bool { return gone[a].Baseline.TotalCalls > gone[b].Baseline.TotalCalls })
+	return append(out, gone...)
+}
@@ -0,0 +1,255 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var (
+	testTenantID    = uuid.MustParse("00000000-0000-0000-0000-0000000000aa")
+	testBaselineID  = uuid.MustParse("00000000-0000-0000-0000-0000000000b1")
+	testCandidateID = uuid.MustParse("00000000-0000-0000-0000-0000000000c1")
+)
+
+func floatPtr(f float64) *float64 { return &f }
+func intPtr(i int) *int           { return &i }
+
+// syntheticPair mocks the stores for a fixed pair of analyses: a one hour
+// baseline and a two hour candidate captured after a change that slowed
+// down two forms, introduced ARERR 302 and removed ARERR 9352.
+func syntheticPair(t *testing.T) (*testutil.MockPostgresStore, *testutil.MockClickHouseStore, *domain.AnalysisJob, *domain.AnalysisJob) {
+	t.Helper()
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+
+	baseDone := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	candDone := time.Date(2026, 3, 9, 9, 45, 0, 0, time.UTC)
+	baseline := &domain.AnalysisJob{ID: testBaselineID, TenantID: testTenantID, FileID: uuid.MustParse("00000000-0000-0000-0000-0000000000f1"), Status: domain.JobStatusComplete, CompletedAt: &baseDone}
+	candidate := &domain.AnalysisJob{ID: testCandidateID, TenantID: testTenantID, FileID: uuid.MustParse("00000000-0000-0000-0000-0000000000f2"), Status: domain.JobStatusComplete, CompletedAt: &candDone}
+
+	pg.On("GetLogFile", mock.Anything, testTenantID, baseline.FileID).Return(&domain.LogFile{Filename: "arserver-before.log"}, nil)
+	pg.On("GetLogFile", mock.Anything, testTenantID, candidate.FileID).Return(&domain.LogFile{Filename: "arserver-after.log"}, nil)
+	pg.On("GetHealthProfile", mock.Anything, testTenantID).Return(nil, fmt.Errorf("postgres: health profile not found: %s", testTenantID))
+
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	tid, bid, cid := testTenantID.String(), testBaselineID.String(), testCandidateID.String()
+	ch.On("GetDashboardData", mock.Anything, tid, bid, dashboardTopN).Return(&domain.DashboardData{GeneralStats: domain.GeneralStatistics{
+		TotalLines: 120000, APICount: 40000, SQLCount: 60000, FilterCount: 18000, EscCount: 2000,
+		UniqueUsers: 85, UniqueForms: 40, UniqueTables: 120, LogStart: domain.NewTimestamp(start), LogEnd: domain.NewTimestamp(start.Add(time.Hour)),
+	}}, nil)
+	ch.On("GetDashboardData", mock.Anything, tid, cid, dashboardTopN).Return(&domain.DashboardData{GeneralStats: domain.GeneralStatistics{
+		TotalLines: 250500, APICount: 82000, SQLCount: 125000, FilterCount: 40000, EscCount: 3500,
+		UniqueUsers: 92, UniqueForms: 41, UniqueTables: 118, LogStart: domain.NewTimestamp(start.AddDate(0, 0, 7)), LogEnd: domain.NewTimestamp(start.AddDate(0, 0, 7).Add(2 * time.Hour)),
+	}}, nil)
+
+	ch.On("GetAggregates", mock.Anything, tid, bid).Return(&domain.AggregatesResponse{API: &domain.AggregateSection{Groups: []domain.AggregateGroup{
+		{Name: "HPD:Help Desk", Count: 9000, AvgMS: 420},
+		{Name: "CHG:Infrastructure Change", Count: 3000, AvgMS: 900},
+		{Name: "SRM:Request", Count: 1500, AvgMS: 250},
+	}}}, nil)
+	ch.On("GetAggregates", mock.Anything, tid, cid).Return(&domain.AggregatesResponse{API: &domain.AggregateSection{Groups: []domain.AggregateGroup{
+		{Name: "HPD:Help Desk", Count: 19000, AvgMS: 1650},
+		{Name: "CHG:Infrastructure Change", Count: 6200, AvgMS: 880},
+		{Name: "SRM:Request", Count: 3100, AvgMS: 310.5},
+		{Name: "PBM:Problem Investigation", Count: 400, AvgMS: 2000},
+	}}}, nil)
+
+	ch.On("GetExceptions", mock.Anything, tid, bid).Return(&domain.ExceptionsResponse{Exceptions: []domain.ExceptionEntry{
+		{ErrorCode: "ARERR 9352", Message: "Timeout during database update", Count: 40},
+		{ErrorCode: "ARERR 93", Message: "Timeout during database query", Count: 10},
+		{ErrorCode: "ARERR 8749", Message: "Password expired", Count: 6},
+	}}, nil)
+	ch.On("GetExceptions", mock.Anything, tid, cid).Return(&domain.ExceptionsResponse{Exceptions: []domain.ExceptionEntry{
+		{ErrorCode: "ARERR 302", Message: "Entry does not exist in database", Count: 75},
+		{ErrorCode: "ARERR 93", Message: "Timeout during database query", Count: 60},
+		{ErrorCode: "ARERR 8749", Message: "Password expired", Count: 13},
+	}}, nil)
+
+	ch.On("ComputeHealthScore", mock.Anything, tid, bid, mock.Anything, mock.Anything).Return(&domain.HealthScore{Score: 86, Status: "green", Factors: []domain.HealthScoreFactor{
+		{Name: "Error Rate", Score: 100}, {Name: "Response Time", Score: 80}, {Name: "Thread Saturation", Score: 80}, {Name: "Gap Frequency", Score: 80},
+	}}, nil)
+	ch.On("ComputeHealthScore", mock.Anything, tid, cid, mock.Anything, mock.Anything).Return(&domain.HealthScore{Score: 58, Status: "yellow", Factors: []domain.HealthScoreFactor{
+		{Name: "Error Rate", Score: 80}, {Name: "Response Time", Score: 50}, {Name: "Thread Saturation", Score: 50}, {Name: "Gap Frequency", Score: 50},
+	}}, nil)
+
+	ch.On("GetGaps", mock.Anything, tid, bid).Return(&domain.GapsResponse{QueueHealth: []domain.QueueHealthSummary{
+		{Queue: "Fast", TotalCalls: 30000, AvgMS: 180, ErrorRate: 0.001},
+		{Queue: "Admin", TotalCalls: 200, AvgMS: 95, ErrorRate: 0},
+	}}, nil)
+	ch.On("GetGaps", mock.Anything, tid, cid).Return(&domain.GapsResponse{QueueHealth: []domain.QueueHealthSummary{
+		{Queue: "List", TotalCalls: 12000, AvgMS: 640, ErrorRate: 0.004},
+		{Queue: "Fast", TotalCalls: 64000, AvgMS: 420, ErrorRate: 0.0125},
+	}}, nil)
+
+	return pg, ch, baseline, candidate
+}
+
+func TestParseSections(t *testing.T) {
+	all, err := ParseSections(nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ComparisonSections, all)
+
+	got, err := ParseSections([]string{"queues", "general", "queues"})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.ComparisonSection{domain.ComparisonGeneral, domain.ComparisonQueues}, got)
+
+	_, err = ParseSections([]string{"general", "threads"})
+	assert.EqualError(t, err, `unknown section "threads"`)
+}
+
+func TestDiffForms_Regressions(t *testing.T) {
+	base := &domain.AggregateSection{Groups: []domain.AggregateGroup{
+		{Name: "A", Count: 10, AvgMS: 100},
+		{Name: "B", Count: 10, AvgMS: 100},
+		{Name: "C", Count: 10, AvgMS: 100},
+	}}
+	cand := &domain.AggregateSection{Groups: []domain.AggregateGroup{
+		{Name: "A", Count: 12, AvgMS: 150},
+		{Name: "B", Count: 8, AvgMS: 90},
+		{Name: "C", Count: 9, AvgMS: 400},
+		{Name: "D", Count: 3, AvgMS: 5000},
+	}}
+
+	forms := DiffForms(base, cand)
+	require.Len(t, forms, 4)
+	assert.Equal(t, "A", forms[0].Form)
+	assert.Equal(t, int64(10), forms[0].BaselineCount)
+	assert.Equal(t, floatPtr(100), forms[0].BaselineAvgMS)
+	assert.Equal(t, 50.0, forms[0].DeltaMS)
+	assert.Nil(t, forms[3].BaselineAvgMS, "new form has no baseline")
+
+	reg := Regressions(forms, 20)
+	require.Len(t, reg, 2, "faster and new forms are not regressions")
+	assert.Equal(t, "C", reg[0].Form)
+	assert.Equal(t, "A", reg[1].Form)
+	assert.Len(t, Regressions(forms, 1), 1)
+
+	assert.Nil(t, DiffForms(base, nil))
+	assert.Len(t, Regressions(DiffForms(nil, cand), 20), 0)
+}
+
+func TestDiffExceptions(t *testing.T) {
+	base := []domain.ExceptionEntry{
+		{ErrorCode: "GONE", Message: "gone", Count: 4},
+		{ErrorCode: "STEADY", Message: "steady", Count: 10},
+		{ErrorCode: "UP", Message: "up", Count: 10},
+	}
+	cand := []domain.ExceptionEntry{
+		{ErrorCode: "NEW-SMALL", Message: "small", Count: 2},
+		{ErrorCode: "STEADY", Message: "steady", Count: 22},
+		{ErrorCode: "UP", Message: "up", Count: 30},
+		// Entries of one code on several queues are summed.
+		{ErrorCode: "NEW-BIG", Message: "big", Count: 5, Queue: "Fast"},
+		{ErrorCode: "NEW-BIG", Message: "big", Count: 5, Queue: "List"},
+	}
+
+	// The candidate log covers twice the time, so STEADY keeps its rate.
+	got := DiffExceptions(base, cand, 1, 2)
+
+	var codes []string
+	for _, e := range got {
+		codes = append(codes, e.ErrorCode+":"+string(e.Change))
+	}
+	assert.Equal(t, []string{"NEW-BIG:appeared", "NEW-SMALL:appeared", "UP:rate_changed", "GONE:disappeared"}, codes)
+	assert.Equal(t, int64(10), got[0].CandidateCount)
+	assert.Equal(t, 5.0, got[0].CandidatePerHour)
+	assert.Equal(t, 10.0, got[2].BaselinePerHour)
+	assert.Equal(t, 15.0, got[2].CandidatePerHour)
+	assert.Equal(t, 4.0, got[3].BaselinePerHour)
+}
+
+func TestDiffExceptions_NoSpanUsesCounts(t *testing.T) {
+	got := DiffExceptions(nil, []domain.ExceptionEntry{{ErrorCode: "X", Count: 7}}, 0, 0)
+	require.Len(t, got, 1)
+	assert.Equal(t, 7.0, got[0].CandidatePerHour)
+}
+
+func TestDiffHealth(t *testing.T) {
+	base := &domain.HealthScore{Score: 90, Factors: []domain.HealthScoreFactor{{Name: "Error Rate", Score: 100}, {Name: "Thread Saturation", Score: 80}}}
+	cand := &domain.HealthScore{Score: 70, Factors: []domain.HealthScoreFactor{{Name: "Response Time", Score: 50}, {Name: "Error Rate", Score: 80}}}
+
+	hc := DiffHealth(base, cand)
+	assert.Equal(t, []domain.HealthFactorComparison{
+		{Name: "Response Time", Candidate: intPtr(50)},
+		{Name: "Error Rate", Baseline: intPtr(100), Candidate: intPtr(80)},
+		{Name: "Thread Saturation", Baseline: intPtr(80)},
+	}, hc.Factors)
+
+	assert.Empty(t, DiffHealth(nil, nil).Factors)
+}
+
+func TestDiffQueues(t *testing.T) {
+	base := []domain.QueueHealthSummary{{Queue: "Admin", TotalCalls: 5}, {Queue: "Fast", TotalCalls: 50}, {Queue: "Escalation", TotalCalls: 20}}
+	cand := []domain.QueueHealthSummary{{Queue: "Fast", TotalCalls: 10}, {Queue: "List", TotalCalls: 30}}
+
+	got := DiffQueues(base, cand)
+	var order []string
+	for _, q := range got {
+		order = append(order, q.Queue)
+	}
+	assert.Equal(t, []string{"List", "Fast", "Escalation", "Admin"}, order)
+	assert.Nil(t, got[0].Baseline)
+	assert.Equal(t, int64(50), got[1].Baseline.TotalCalls)
+	assert.Nil(t, got[2].Candidate)
+}
+
+func TestComparer_Compare(t *testing.T) {
+	pg, ch, baseline, candidate := syntheticPair(t)
+
+	cmp, err := NewComparer(pg, ch).Compare(context.Background(), testTenantID, baseline, candidate, domain.ComparisonSections)
+	require.NoError(t, err)
+
+	assert.Equal(t, "arserver-before.log", cmp.Baseline.Filename)
+	assert.Equal(t, testCandidateID, cmp.Candidate.JobID)
+	require.Len(t, cmp.Stats, 9)
+	assert.Equal(t, domain.StatComparison{Name: "API calls", Baseline: 40000, Candidate: 82000, Delta: 42000}, cmp.Stats[1])
+	require.Len(t, cmp.Forms, 2)
+	assert.Equal(t, "HPD:Help Desk", cmp.Forms[0].Form)
+	require.Len(t, cmp.Exceptions, 3)
+	assert.Equal(t, domain.ExceptionAppeared, cmp.Exceptions[0].Change)
+	assert.Equal(t, domain.ExceptionRateChanged, cmp.Exceptions[1].Change)
+	assert.Equal(t, domain.ExceptionDisappeared, cmp.Exceptions[2].Change)
+	require.NotNil(t, cmp.Health)
+	assert.Equal(t, 58, cmp.Health.Candidate.Score)
+	require.Len(t, cmp.Queues, 3)
+	assert.Equal(t, "Fast", cmp.Queues[0].Queue)
+	ch.AssertExpectations(t)
+}
+
+func TestComparer_CompareOnlyRequestedSections(t *testing.T) {
+	pg, ch, baseline, candidate := syntheticPair(t)
+
+	cmp, err := NewComparer(pg, ch).Compare(context.Background(), testTenantID, baseline, candidate,
+		[]domain.ComparisonSection{domain.ComparisonForms})
+	require.NoError(t, err)
+
+	assert.Len(t, cmp.Forms, 2)
+	assert.Nil(t, cmp.Stats)
+	assert.Nil(t, cmp.Health)
+	ch.AssertNotCalled(t, "GetDashboardData", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	ch.AssertNotCalled(t, "GetGaps", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestComparer_CompareStoreError(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	baseline := &domain.AnalysisJob{ID: testBaselineID}
+	candidate := &domain.AnalysisJob{ID: testCandidateID}
+	pg.On("GetLogFile", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("no file"))
+	ch.On("GetGaps", mock.Anything, testTenantID.String(), testBaselineID.String()).Return(nil, errors.New("clickhouse down"))
+
+	_, err := NewComparer(pg, ch).Compare(context.Background(), testTenantID, baseline, candidate,
+		[]domain.ComparisonSection{domain.ComparisonQueues})
+	assert.EqualError(t, err, "baseline queue health: clickhouse down")
+}
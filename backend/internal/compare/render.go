@@ -0,0 +1,350 @@
+package compare
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var reportTemplate = template.Must(template.New("comparison").Funcs(template.FuncMap{
+	"has": hasSection,
+	"label": func(a domain.ComparedAnalysis) string {
+		if a.Filename != "" {
+			return a.Filename
+		}
+		return a.JobID.String()
+	},
+	"fmtTime": func(t *domain.Timestamp) string {
+		if t == nil {
+			return "-"
+		}
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	"fmtNum": formatNumber,
+	"fmtDelta": func(f float64) template.HTML {
+		return signed(formatNumber(f), f)
+	},
+	"fmtMS": formatMS,
+	"fmtDeltaMS": func(f float64) template.HTML {
+		return signed(formatMS(f), f)
+	},
+	"fmtRate": func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	},
+	"fmtScore": func(s *int) string {
+		if s == nil {
+			return "-"
+		}
+		return strconv.Itoa(*s)
+	},
+	"scoreDelta": func(base, cand *int) template.HTML {
+		if base == nil || cand == nil {
+			return "-"
+		}
+		return template.HTML(fmt.Sprintf("%+d", *cand-*base))
+	},
+	"overallDelta": func(h *domain.HealthComparison) template.HTML {
+		if h.Baseline == nil || h.Candidate == nil {
+			return "-"
+		}
+		return template.HTML(fmt.Sprintf("%+d", h.Candidate.Score-h.Baseline.Score))
+	},
+	"fmtPct": func(f float64) string {
+		return strconv.FormatFloat(f*100, 'f', 2, 64) + "%"
+	},
+	"deltaClass": func(f float64) string {
+		switch {
+		case f > 0:
+			return "up"
+		case f < 0:
+			return "down"
+		}
+		return ""
+	},
+}).Parse(reportHTML))
+
+func formatNumber(f float64) string {
+	if f == float64(int64(f)) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+func formatMS(f float64) string {
+	if f < 1000 && f > -1000 {
+		return fmt.Sprintf("%.1f ms", f)
+	}
+	return fmt.Sprintf("%.2f s", f/1000)
+}
+
+// signed prefixes a formatted positive value with '+'. The result holds
+// only a formatted number and is safe to emit unescaped.
+func signed(s string, f float64) template.HTML {
+	if f > 0 {
+		return template.HTML("+" + s)
+	}
+	return template.HTML(s)
+}
+
+// RenderHTML renders a comparison as a self-contained HTML document.
+func RenderHTML(c *domain.AnalysisComparison) ([]byte, error) {
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, c); err != nil {
+		return nil, fmt.Errorf("render comparison: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// WriteZIP writes a zip archive holding the HTML report, one CSV file per
+// section of the comparison and, when set, its provenance.json.
+func WriteZIP(w io.Writer, c *domain.AnalysisComparison) error {
+	html, err := RenderHTML(c)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	add := func(name string, write func(io.Writer) error) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: c.GeneratedAt.Time})
+		if err != nil {
+			return fmt.Errorf("zip %s: %w", name, err)
+		}
+		if err := write(f); err != nil {
+			return fmt.Errorf("zip %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := add("comparison.html", func(w io.Writer) error {
+		_, err := w.Write(html)
+		return err
+	}); err != nil {
+		return err
+	}
+	if c.Provenance != nil {
+		if err := add("provenance.json", func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(c.Provenance)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.Sections {
+		rows := sectionCSV(c, s)
+		if err := add(string(s)+".csv", func(w io.Writer) error {
+			cw := csv.NewWriter(w)
+			if err := cw.WriteAll(rows); err != nil {
+				return err
+			}
+			return cw.Error()
+		}); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// sectionCSV returns the CSV rows, header first, of one comparison section.
+func sectionCSV(c *domain.AnalysisComparison, s domain.ComparisonSection) [][]string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	optF := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return f(*v)
+	}
+	optI := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	i64 := func(v int64) string { return strconv.FormatInt(v, 10) }
+
+	switch s {
+	case domain.ComparisonGeneral:
+		rows := [][]string{{"statistic", "baseline", "candidate", "delta"}}
+		for _, st := range c.Stats {
+			rows = append(rows, []string{st.Name, f(st.Baseline), f(st.Candidate), f(st.Delta)})
+		}
+		return rows
+
+	case domain.ComparisonForms:
+		rows := [][]string{{"form", "baseline_count", "baseline_avg_ms", "candidate_count", "candidate_avg_ms", "delta_ms"}}
+		for _, fm := range c.Forms {
+			rows = append(rows, []string{fm.Form, i64(fm.BaselineCount), optF(fm.BaselineAvgMS), i64(fm.CandidateCount), f(fm.CandidateAvgMS), f(fm.DeltaMS)})
+		}
+		return rows
+
+	case domain.ComparisonExceptions:
+		rows := [][]string{{"error_code", "change", "baseline_count", "candidate_count", "baseline_per_hour", "candidate_per_hour", "message"}}
+		for _, e := range c.Exceptions {
+			rows = append(rows, []string{e.ErrorCode, string(e.Change), i64(e.BaselineCount), i64(e.CandidateCount), f(e.BaselinePerHour), f(e.CandidatePerHour), e.Message})
+		}
+		return rows
+
+	case domain.ComparisonHealth:
+		rows := [][]string{{"factor", "baseline", "candidate"}}
+		if c.Health == nil {
+			return rows
+		}
+		score := func(h *domain.HealthScore) *int {
+			if h == nil {
+				return nil
+			}
+			return &h.Score
+		}
+		rows = append(rows, []string{"Overall", optI(score(c.Health.Baseline)), optI(score(c.Health.Candidate))})
+		for _, hf := range c.Health.Factors {
+			rows = append(rows, []string{hf.Name, optI(hf.Baseline), optI(hf.Candidate)})
+		}
+		return rows
+
+	case domain.ComparisonQueues:
+		rows := [][]string{{"queue", "baseline_calls", "baseline_avg_ms", "baseline_error_rate", "candidate_calls", "candidate_avg_ms", "candidate_error_rate"}}
+		side := func(q *domain.QueueHealthSummary) []string {
+			if q == nil {
+				return []string{"", "", ""}
+			}
+			return []string{i64(q.TotalCalls), f(q.AvgMS), f(q.ErrorRate)}
+		}
+		for _, q := range c.Queues {
+			row := append([]string{q.Queue}, side(q.Baseline)...)
+			rows = append(rows, append(row, side(q.Candidate)...))
+		}
+		return rows
+	}
+	return nil
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>RemedyIQ analysis comparison</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2937; max-width: 960px; margin: 24px auto; padding: 0 16px; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 17px; margin-top: 32px; border-bottom: 2px solid #e5e7eb; padding-bottom: 4px; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; }
+th { text-align: left; background: #f9fafb; border-bottom: 1px solid #e5e7eb; padding: 6px; }
+td { border-bottom: 1px solid #f3f4f6; padding: 6px; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+.up { color: #b91c1c; }
+.down { color: #047857; }
+.muted { color: #6b7280; }
+.tag { display: inline-block; padding: 1px 6px; border-radius: 4px; font-size: 11px; background: #f3f4f6; }
+.tag.appeared { background: #fee2e2; color: #991b1b; }
+.tag.disappeared { background: #d1fae5; color: #065f46; }
+.tag.rate_changed { background: #fef3c7; color: #92400e; }
+</style>
+</head>
+<body>
+<h1>Analysis comparison</h1>
+<table>
+<tr><th></th><th>Analysis</th><th>Completed</th></tr>
+<tr><td>Baseline</td><td>{{label .Baseline}}</td><td>{{fmtTime .Baseline.CompletedAt}}</td></tr>
+<tr><td>Candidate</td><td>{{label .Candidate}}</td><td>{{fmtTime .Candidate.CompletedAt}}</td></tr>
+</table>
+<p class="muted">Generated {{.GeneratedAt.UTC.Format "2006-01-02 15:04 UTC"}}. Deltas are candidate minus baseline.</p>
+{{- with .CaptureDrift}}
+
+<h2>Capture drift</h2>
+<p>The captures were taken differently, which may account for other differences below.</p>
+<ul class="drift">
+{{- range .}}
+<li>{{.Message}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if has .Sections "general"}}
+
+<h2>General statistics</h2>
+<table>
+<tr><th>Statistic</th><th class="num">Baseline</th><th class="num">Candidate</th><th class="num">Change</th></tr>
+{{- range .Stats}}
+<tr><td>{{.Name}}</td><td class="num">{{fmtNum .Baseline}}</td><td class="num">{{fmtNum .Candidate}}</td><td class="num">{{fmtDelta .Delta}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if has .Sections "forms"}}
+
+<h2>Form regressions</h2>
+{{- if not .Forms}}
+<p>No form got slower than in the baseline.</p>
+{{- else}}
+<table>
+<tr><th>Form</th><th class="num">Baseline calls</th><th class="num">Baseline avg</th><th class="num">Candidate calls</th><th class="num">Candidate avg</th><th class="num">Change</th></tr>
+{{- range .Forms}}
+<tr><td>{{.Form}}</td><td class="num">{{.BaselineCount}}</td><td class="num">{{with .BaselineAvgMS}}{{fmtMS .}}{{end}}</td><td class="num">{{.CandidateCount}}</td><td class="num">{{fmtMS .CandidateAvgMS}}</td><td class="num {{deltaClass .DeltaMS}}">{{fmtDeltaMS .DeltaMS}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- if has .Sections "exceptions"}}
+
+<h2>Exception codes</h2>
+{{- if not .Exceptions}}
+<p>No exception code appeared, disappeared or changed rate.</p>
+{{- else}}
+<table>
+<tr><th>Code</th><th>Change</th><th class="num">Baseline /h</th><th class="num">Candidate /h</th><th class="num">Baseline count</th><th class="num">Candidate count</th><th>Message</th></tr>
+{{- range .Exceptions}}
+<tr><td>{{.ErrorCode}}</td><td><span class="tag {{.Change}}">{{.Change}}</span></td><td class="num">{{fmtRate .BaselinePerHour}}</td><td class="num">{{fmtRate .CandidatePerHour}}</td><td class="num">{{.BaselineCount}}</td><td class="num">{{.CandidateCount}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- if has .Sections "health"}}
+
+<h2>Health score</h2>
+{{- with .Health}}
+<table>
+<tr><th>Factor</th><th class="num">Baseline</th><th class="num">Candidate</th><th class="num">Change</th></tr>
+<tr><td><strong>Overall</strong></td><td class="num">{{with .Baseline}}{{.Score}} ({{.Status}}){{else}}-{{end}}</td><td class="num">{{with .Candidate}}{{.Score}} ({{.Status}}){{else}}-{{end}}</td><td class="num">{{overallDelta .}}</td></tr>
+{{- range .Factors}}
+<tr><td>{{.Name}}</td><td class="num">{{fmtScore .Baseline}}</td><td class="num">{{fmtScore .Candidate}}</td><td class="num">{{scoreDelta .Baseline .Candidate}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- if has .Sections "queues"}}
+
+<h2>Queue health</h2>
+{{- if not .Queues}}
+<p>No queue activity was recorded.</p>
+{{- else}}
+<table>
+<tr><th>Queue</th><th class="num">Baseline calls</th><th class="num">Candidate calls</th><th class="num">Baseline avg</th><th class="num">Candidate avg</th><th class="num">Baseline errors</th><th class="num">Candidate errors</th></tr>
+{{- range .Queues}}
+<tr><td>{{.Queue}}</td>
+{{- with .Baseline}}<td class="num">{{.TotalCalls}}</td>{{else}}<td class="num muted">-</td>{{end}}
+{{- with .Candidate}}<td class="num">{{.TotalCalls}}</td>{{else}}<td class="num muted">-</td>{{end}}
+{{- with .Baseline}}<td class="num">{{fmtMS .AvgMS}}</td>{{else}}<td class="num muted">-</td>{{end}}
+{{- with .Candidate}}<td class="num">{{fmtMS .AvgMS}}</td>{{else}}<td class="num muted">-</td>{{end}}
+{{- with .Baseline}}<td class="num">{{fmtPct .ErrorRate}}</td>{{else}}<td class="num muted">-</td>{{end}}
+{{- with .Candidate}}<td class="num">{{fmtPct .ErrorRate}}</td>{{else}}<td class="num muted">-</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- with .Provenance}}
+
+<h2>Provenance</h2>
+<table class="provenance">
+{{- range .Fields}}
+<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`
@@ -0,0 +1,151 @@
+package compare
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files in testdata")
+
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("..", "..", "testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got)
+}
+
+// syntheticComparison compares the synthetic pair with a fixed generation time.
+func syntheticComparison(t *testing.T, sections []domain.ComparisonSection) *domain.AnalysisComparison {
+	t.Helper()
+	pg, ch, baseline, candidate := syntheticPair(t)
+	cmp, err := NewComparer(pg, ch).Compare(context.Background(), testTenantID, baseline, candidate, sections)
+	require.NoError(t, err)
+	cmp.GeneratedAt = domain.NewTimestamp(time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC))
+	return cmp
+}
+
+func TestRenderHTML_Golden(t *testing.T) {
+	html, err := RenderHTML(syntheticComparison(t, domain.ComparisonSections))
+	require.NoError(t, err)
+	assertGolden(t, "comparison_report.golden.html", string(html))
+}
+
+func TestRenderHTML_SelectedSections(t *testing.T) {
+	html, err := RenderHTML(syntheticComparison(t, []domain.ComparisonSection{domain.ComparisonHealth}))
+	require.NoError(t, err)
+
+	assert.Contains(t, string(html), "<h2>Health score</h2>")
+	assert.NotContains(t, string(html), "<h2>General statistics</h2>")
+	assert.NotContains(t, string(html), "<h2>Queue health</h2>")
+}
+
+func TestRenderHTML_EscapesLogContent(t *testing.T) {
+	cmp := &domain.AnalysisComparison{
+		Sections:   []domain.ComparisonSection{domain.ComparisonExceptions},
+		Exceptions: []domain.ExceptionComparison{{ErrorCode: "ARERR 1", Message: "<script>alert(1)</script>", Change: domain.ExceptionAppeared}},
+	}
+	html, err := RenderHTML(cmp)
+	require.NoError(t, err)
+	assert.NotContains(t, string(html), "<script>")
+	assert.Contains(t, string(html), "&lt;script&gt;")
+}
+
+func TestRenderHTML_CaptureDrift(t *testing.T) {
+	cmp := &domain.AnalysisComparison{
+		Sections:     []domain.ComparisonSection{domain.ComparisonHealth},
+		CaptureDrift: []domain.CaptureDriftWarning{{Kind: domain.CaptureDriftLogType, Field: "sql", Message: "SQL logging absent in this capture but present in the baseline capture"}},
+	}
+	html, err := RenderHTML(cmp)
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "<h2>Capture drift</h2>")
+	assert.Contains(t, string(html), "<li>SQL logging absent in this capture but present in the baseline capture</li>")
+
+	html, err = RenderHTML(&domain.AnalysisComparison{Sections: cmp.Sections})
+	require.NoError(t, err)
+	assert.NotContains(t, string(html), "Capture drift")
+}
+
+func TestWriteZIP(t *testing.T) {
+	cmp := syntheticComparison(t, domain.ComparisonSections)
+	var buf bytes.Buffer
+	require.NoError(t, WriteZIP(&buf, cmp))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		names = append(names, f.Name)
+		assert.True(t, f.Modified.Equal(cmp.GeneratedAt.Time), "%s modified %s", f.Name, f.Modified)
+	}
+	assert.Equal(t, []string{"comparison.html", "general.csv", "forms.csv", "exceptions.csv", "health.csv", "queues.csv"}, names)
+
+	html, err := RenderHTML(cmp)
+	require.NoError(t, err)
+	assert.Equal(t, html, files["comparison.html"])
+
+	readCSV := func(name string) [][]string {
+		rows, err := csv.NewReader(bytes.NewReader(files[name])).ReadAll()
+		require.NoError(t, err)
+		return rows
+	}
+	assert.Equal(t, []string{"Log duration (h)", "1", "2", "1"}, readCSV("general.csv")[9])
+	assert.Equal(t, []string{"HPD:Help Desk", "9000", "420", "19000", "1650", "1230"}, readCSV("forms.csv")[1])
+	assert.Equal(t, []string{"ARERR 9352", "disappeared", "40", "0", "40", "0", "Timeout during database update"}, readCSV("exceptions.csv")[3])
+	assert.Equal(t, []string{"Overall", "86", "58"}, readCSV("health.csv")[1])
+	assert.Equal(t, []string{"Admin", "200", "95", "0", "", "", ""}, readCSV("queues.csv")[3])
+}
+
+func TestRender_Provenance(t *testing.T) {
+	cmp := syntheticComparison(t, []domain.ComparisonSection{domain.ComparisonGeneral})
+	completed := time.Date(2026, 3, 8, 9, 30, 0, 0, time.UTC)
+	cmp.Provenance = &domain.ArtifactProvenance{
+		Format:        domain.ExportFormatComparisonZIP,
+		Analyses:      []domain.ProvenanceAnalysis{{JobID: cmp.Candidate.JobID, CompletedAt: &completed}},
+		ParserVersion: "2",
+		GeneratedBy:   "user-1",
+		GeneratedAt:   cmp.GeneratedAt.Time,
+	}
+
+	html, err := RenderHTML(cmp)
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "<h2>Provenance</h2>")
+	assert.Contains(t, string(html), "<tr><td>analysis</td><td>"+cmp.Candidate.JobID.String()+" completed 2026-03-08T09:30:00Z</td></tr>")
+	assert.Contains(t, string(html), "<tr><td>generated_by</td><td>user-1</td></tr>")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteZIP(&buf, cmp))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 3)
+	assert.Equal(t, "provenance.json", zr.File[1].Name)
+	rc, err := zr.File[1].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	var got domain.ArtifactProvenance
+	require.NoError(t, json.NewDecoder(rc).Decode(&got))
+	assert.Equal(t, *cmp.Provenance, got)
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 )
 
 // setEnvs sets multiple environment variables and returns a cleanup function.
@@ -27,6 +29,8 @@ func TestLoad_DefaultValues(t *testing.T) {
 	assert.Contains(t, cfg.ClickHouseURL, "localhost:9004")
 	assert.Contains(t, cfg.NATSURL, "localhost:4222")
 	assert.Contains(t, cfg.RedisURL, "localhost:6379")
+	assert.Equal(t, "s3", cfg.StorageBackend)
+	assert.Equal(t, "./data/objects", cfg.StorageFSRoot)
 	assert.Equal(t, "http://localhost:9002", cfg.S3Endpoint)
 	assert.Equal(t, "minioadmin", cfg.S3AccessKey)
 	assert.Equal(t, "minioadmin", cfg.S3SecretKey)
@@ -35,6 +39,24 @@ func TestLoad_DefaultValues(t *testing.T) {
 	assert.True(t, cfg.S3SkipBucketVerification)
 	assert.Equal(t, 4096, cfg.JARDefaultHeapMB)
 	assert.Equal(t, 1800, cfg.JARTimeoutSec)
+	assert.Empty(t, cfg.JARLegacyPaths)
+	assert.Equal(t, 500000, cfg.JARMaxSectionRows)
+	assert.Equal(t, 2, cfg.WorkerMaxConcurrentJobs)
+	assert.Equal(t, 8192, cfg.WorkerHeapBudgetMB)
+	assert.Equal(t, 5000, cfg.ClockSkewThresholdMS)
+	assert.Equal(t, float64(1), cfg.DataQualityMinorPct)
+	assert.Equal(t, float64(5), cfg.DataQualityMajorPct)
+	assert.Equal(t, 60, cfg.ExportURLExpiryMin)
+	assert.Equal(t, 7, cfg.ExportRetentionDays)
+	assert.Equal(t, 1000000, cfg.ExportMaxRows)
+	assert.Equal(t, 30, cfg.TrashGraceDays)
+	assert.Empty(t, cfg.SMTPHost)
+	assert.Equal(t, 587, cfg.SMTPPort)
+	assert.Empty(t, cfg.ClickHouseStoragePolicy)
+	assert.Equal(t, "cold", cfg.ClickHouseColdVolume)
+	assert.Empty(t, cfg.AdminUserIDs)
+	assert.Empty(t, cfg.NATSCredsFile)
+	assert.Empty(t, cfg.NATSUser)
 	assert.Equal(t, "", cfg.ClerkSecretKey)
 	assert.Equal(t, "", cfg.AnthropicAPIKey)
 	assert.Equal(t, "development", cfg.Environment)
@@ -138,6 +160,310 @@ func TestLoad_Validate_AllPresent(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestLoad_Validate_DataQualityThresholds(t *testing.T) {
+	cfg := &Config{
+		PostgresURL:         "postgres://localhost:5432/db",
+		ClickHouseURL:       "clickhouse://localhost:9004/db",
+		NATSURL:             "nats://localhost:4222",
+		DataQualityMinorPct: 10,
+		DataQualityMajorPct: 5,
+	}
+	err := cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DATA_QUALITY_MINOR_PCT")
+
+	cfg.DataQualityMajorPct = 10
+	assert.NoError(t, cfg.validate())
+}
+
+func TestLoad_Validate_UploadLimits(t *testing.T) {
+	cfg := &Config{
+		PostgresURL:             "postgres://localhost:5432/db",
+		ClickHouseURL:           "clickhouse://localhost:9004/db",
+		NATSURL:                 "nats://localhost:4222",
+		UploadGlobalBytesPerSec: -1,
+	}
+	err := cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UPLOAD_GLOBAL_BYTES_PER_SEC")
+
+	cfg.UploadGlobalBytesPerSec = 90 << 20
+	cfg.APIReplicas = 3
+	require.NoError(t, cfg.validate())
+	assert.Equal(t, 30<<20, cfg.UploadReplicaBytesPerSec())
+}
+
+func TestLoad_Validate_FocusWindowWidth(t *testing.T) {
+	cfg := &Config{
+		PostgresURL:           "postgres://localhost:5432/db",
+		ClickHouseURL:         "clickhouse://localhost:9004/db",
+		NATSURL:               "nats://localhost:4222",
+		FocusWindowMinMinutes: 180,
+		FocusWindowMaxMinutes: 120,
+	}
+	err := cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "FOCUS_WINDOW_MIN_MINUTES")
+
+	cfg.FocusWindowMinMinutes = 120
+	assert.NoError(t, cfg.validate())
+}
+
+func TestLoad_Validate_APIV1Sunset(t *testing.T) {
+	cfg := &Config{
+		PostgresURL:   "postgres://localhost:5432/db",
+		ClickHouseURL: "clickhouse://localhost:9004/db",
+		NATSURL:       "nats://localhost:4222",
+		APIV1Sunset:   "30/06/2027",
+	}
+	err := cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API_V1_SUNSET")
+
+	cfg.APIV1Sunset = "2027-06-30"
+	assert.NoError(t, cfg.validate())
+}
+
+func TestLoad_Validate_ClickHouseClusters(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			PostgresURL:            "postgres://localhost:5432/db",
+			ClickHouseURL:          "clickhouse://localhost:9004/db",
+			NATSURL:                "nats://localhost:4222",
+			ClickHouseClusters:     map[string]string{"eu": "clickhouse://ch-eu:9000/db"},
+			ClickHouseRouteSyncSec: 30,
+		}
+	}
+	require.NoError(t, base().validate())
+
+	cfg := base()
+	cfg.ClickHouseClusters["default"] = "clickhouse://other:9000/db"
+	err := cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLICKHOUSE_CLUSTERS")
+
+	cfg = base()
+	cfg.ClickHouseRouteSyncSec = 0
+	err = cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLICKHOUSE_ROUTE_SYNC_SEC")
+}
+
+func TestLoad_StorageRegions(t *testing.T) {
+	setEnvs(t, map[string]string{
+		"S3_ENDPOINT":                            "s3.us-east-1.amazonaws.com",
+		"S3_ACCESS_KEY":                          "AKIAUS",
+		"S3_SECRET_KEY":                          "us-secret",
+		"STORAGE_REGIONS":                        "eu, eu-north",
+		"STORAGE_REGION_EU_S3_ENDPOINT":          "s3.eu-central-1.amazonaws.com",
+		"STORAGE_REGION_EU_S3_BUCKET":            "remedyiq-eu",
+		"STORAGE_REGION_EU_S3_ACCESS_KEY":        "AKIAEU",
+		"STORAGE_REGION_EU_CLICKHOUSE_URL":       "clickhouse://ch-eu:9000/db",
+		"STORAGE_REGION_EU_NORTH_S3_BUCKET":      "remedyiq-eu-north",
+		"STORAGE_REGION_EU_NORTH_CLICKHOUSE_URL": "clickhouse://ch-eu-north:9000/db",
+	})
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	require.Len(t, cfg.StorageRegions, 2)
+	eu := cfg.StorageRegions["eu"]
+	assert.Equal(t, "s3.eu-central-1.amazonaws.com", eu.S3Endpoint)
+	assert.Equal(t, "AKIAEU", eu.S3AccessKey)
+	assert.Equal(t, "us-secret", eu.S3SecretKey, "credentials default to the default storage's")
+	assert.Equal(t, "clickhouse://ch-eu:9000/db", eu.ClickHouseURL)
+	assert.Equal(t, "s3.us-east-1.amazonaws.com", cfg.StorageRegions["eu-north"].S3Endpoint)
+}
+
+func TestConfig_RegionConfigs(t *testing.T) {
+	cfg := &Config{
+		S3SkipBucketVerification: true,
+		StorageRegions: map[string]StorageRegion{
+			"eu": {S3Endpoint: "s3.eu-central-1.amazonaws.com", S3AccessKey: "AKIAEU", S3SecretKey: "eu-secret",
+				S3Bucket: "remedyiq-eu", S3UseSSL: true, ClickHouseURL: "clickhouse://ch-eu:9000/db"},
+		},
+	}
+
+	regions := cfg.RegionConfigs()
+	require.Len(t, regions, 1)
+	assert.Equal(t, storage.RegionConfig{
+		Objects: storage.ObjectStorageConfig{
+			Backend: storage.BackendS3, S3Endpoint: "s3.eu-central-1.amazonaws.com", S3AccessKey: "AKIAEU",
+			S3SecretKey: "eu-secret", S3Bucket: "remedyiq-eu", S3UseSSL: true, S3SkipBucketVerification: true,
+		},
+		ClickHouseURL: "clickhouse://ch-eu:9000/db",
+	}, regions["eu"])
+	assert.Empty(t, (&Config{}).RegionConfigs())
+}
+
+func TestLoad_Validate_StorageRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"reserved name", func(c *Config) {
+			c.StorageRegions["default"] = StorageRegion{S3Bucket: "b", ClickHouseURL: "clickhouse://x:9000/db"}
+		}, "invalid region name"},
+		{"malformed name", func(c *Config) {
+			c.StorageRegions["EU West"] = StorageRegion{S3Bucket: "b", ClickHouseURL: "clickhouse://x:9000/db"}
+		}, "invalid region name"},
+		{"no bucket", func(c *Config) {
+			c.StorageRegions["eu"] = StorageRegion{ClickHouseURL: "clickhouse://ch-eu:9000/db"}
+		}, "needs an S3 bucket"},
+		{"default bucket", func(c *Config) {
+			c.StorageRegions["eu"] = StorageRegion{S3Endpoint: "s3.local", S3Bucket: "logs", ClickHouseURL: "clickhouse://ch-eu:9000/db"}
+		}, "shares its bucket with the default storage"},
+		{"default ClickHouse", func(c *Config) {
+			c.StorageRegions["eu"] = StorageRegion{S3Bucket: "logs-eu", ClickHouseURL: c.ClickHouseURL}
+		}, "shares its ClickHouse with the default storage"},
+		{"shared between regions", func(c *Config) {
+			c.StorageRegions["apac"] = StorageRegion{S3Bucket: "logs-apac", ClickHouseURL: "clickhouse://ch-eu:9000/db"}
+		}, "region eu shares its ClickHouse with region apac"},
+		{"cluster of the same name", func(c *Config) {
+			c.ClickHouseClusters = map[string]string{"eu": "clickhouse://other:9000/db"}
+		}, "also a cluster of CLICKHOUSE_CLUSTERS"},
+		{"other backend", func(c *Config) { c.StorageBackend = "filesystem" }, "requires STORAGE_BACKEND=s3"},
+		{"no health interval", func(c *Config) { c.StorageRegionHealthSec = 0 }, "STORAGE_REGION_HEALTH_SEC"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				PostgresURL:            "postgres://localhost:5432/db",
+				ClickHouseURL:          "clickhouse://localhost:9004/db",
+				NATSURL:                "nats://localhost:4222",
+				S3Endpoint:             "s3.local",
+				S3Bucket:               "logs",
+				ClickHouseRouteSyncSec: 30,
+				StorageRegionHealthSec: 30,
+				StorageRegions: map[string]StorageRegion{
+					"eu": {S3Endpoint: "s3.local", S3Bucket: "logs-eu", ClickHouseURL: "clickhouse://ch-eu:9000/db"},
+				},
+			}
+			tc.mutate(cfg)
+			err := cfg.validate()
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestLoad_Validate_NATSAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{"no credentials", func(c *Config) {}, ""},
+		{"creds file", func(c *Config) { c.NATSCredsFile = "/etc/nats/api.creds" }, ""},
+		{"user and password", func(c *Config) { c.NATSUser, c.NATSPassword = "worker", "s3cret" }, ""},
+		{"two methods", func(c *Config) {
+			c.NATSCredsFile = "/etc/nats/api.creds"
+			c.NATSNKeySeedFile = "/etc/nats/api.nk"
+		}, "only one of NATS_CREDS_FILE"},
+		{"user without password", func(c *Config) { c.NATSUser = "worker" }, "NATS_PASSWORD is required"},
+		{"password without user", func(c *Config) { c.NATSPassword = "s3cret" }, "NATS_USER is required"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				PostgresURL:   "postgres://localhost:5432/db",
+				ClickHouseURL: "clickhouse://localhost:9004/db",
+				NATSURL:       "nats://localhost:4222",
+			}
+			tc.mutate(cfg)
+			err := cfg.validate()
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestLoad_Validate_Auth(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{"clerk", func(c *Config) { c.AuthProvider, c.ClerkSecretKey = "clerk", "sk_test" }, ""},
+		{"local", func(c *Config) {
+			c.AuthProvider, c.LocalAuthSigningKeys = "local", []string{"0123456789abcdef0123456789abcdef"}
+		}, ""},
+		{"local with clerk secret", func(c *Config) {
+			c.AuthProvider, c.LocalAuthSigningKeys = "local", []string{"0123456789abcdef0123456789abcdef"}
+			c.ClerkSecretKey = "sk_live"
+		}, "cannot be used with CLERK_SECRET_KEY"},
+		{"local without keys", func(c *Config) { c.AuthProvider = "local" }, "LOCAL_AUTH_SIGNING_KEYS is required"},
+		{"unknown", func(c *Config) { c.AuthProvider = "ldap" }, "AUTH_PROVIDER must be"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				PostgresURL:   "postgres://localhost:5432/db",
+				ClickHouseURL: "clickhouse://localhost:9004/db",
+				NATSURL:       "nats://localhost:4222",
+			}
+			tc.mutate(cfg)
+			err := cfg.validate()
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestLoad_Validate_Storage(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{"s3", func(c *Config) { c.StorageBackend = "s3" }, ""},
+		{"filesystem", func(c *Config) { c.StorageBackend = "filesystem" }, ""},
+		{"azure", func(c *Config) {
+			c.StorageBackend = "azure"
+			c.AzureStorageAccount, c.AzureStorageKey, c.AzureStorageContainer = "remedyiq", "a2V5", "logs"
+		}, ""},
+		{"azure without key", func(c *Config) {
+			c.StorageBackend = "azure"
+			c.AzureStorageAccount, c.AzureStorageContainer = "remedyiq", "logs"
+		}, "AZURE_STORAGE_KEY"},
+		{"gcs without bucket", func(c *Config) {
+			c.StorageBackend = "gcs"
+			c.GCSAccessKey, c.GCSSecretKey = "GOOG1", "secret"
+		}, "GCS_BUCKET"},
+		{"unknown backend", func(c *Config) { c.StorageBackend = "ftp" }, "STORAGE_BACKEND must be one of"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				PostgresURL:   "postgres://localhost:5432/db",
+				ClickHouseURL: "clickhouse://localhost:9004/db",
+				NATSURL:       "nats://localhost:4222",
+			}
+			tc.mutate(cfg)
+			err := cfg.validate()
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
 func TestIsDevelopment(t *testing.T) {
 	tests := []struct {
 		env  string
@@ -187,6 +513,18 @@ func TestGetEnvInt(t *testing.T) {
 	})
 }
 
+func TestGetEnvFloat(t *testing.T) {
+	t.Run("returns parsed float when valid", func(t *testing.T) {
+		t.Setenv("TEST_FLOAT_KEY", "2.5")
+		assert.Equal(t, 2.5, getEnvFloat("TEST_FLOAT_KEY", 1))
+	})
+
+	t.Run("returns fallback when invalid float", func(t *testing.T) {
+		t.Setenv("TEST_FLOAT_KEY_BAD", "a lot")
+		assert.Equal(t, float64(1), getEnvFloat("TEST_FLOAT_KEY_BAD", 1))
+	})
+}
+
 func TestGetEnvBool(t *testing.T) {
 	t.Run("returns true when set to true", func(t *testing.T) {
 		t.Setenv("TEST_BOOL_KEY", "true")
@@ -218,3 +556,23 @@ func TestGetEnvBool(t *testing.T) {
 		assert.False(t, getEnvBool("TEST_BOOL_KEY_ZERO", true))
 	})
 }
+
+func TestGetEnvMap(t *testing.T) {
+	t.Run("parses key=value pairs", func(t *testing.T) {
+		t.Setenv("TEST_MAP_KEY", "9.x=/opt/jar9/a.jar, 20.x = /opt/jar20/b.jar")
+		assert.Equal(t, map[string]string{
+			"9.x":  "/opt/jar9/a.jar",
+			"20.x": "/opt/jar20/b.jar",
+		}, getEnvMap("TEST_MAP_KEY"))
+	})
+
+	t.Run("skips malformed pairs", func(t *testing.T) {
+		t.Setenv("TEST_MAP_KEY_BAD", "9.x,=/a.jar,20.x=,25.x=/c.jar")
+		assert.Equal(t, map[string]string{"25.x": "/c.jar"}, getEnvMap("TEST_MAP_KEY_BAD"))
+	})
+
+	t.Run("returns empty map when not set", func(t *testing.T) {
+		os.Unsetenv("TEST_MAP_KEY_MISSING")
+		assert.Empty(t, getEnvMap("TEST_MAP_KEY_MISSING"))
+	})
+}
@@ -0,0 +1,386 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// Dynamic is the part of the configuration that can be changed while the
+// servers run, through PUT /api/v1/admin/settings. Its values change under
+// the components using them, so they read it each time they use a value
+// rather than when they are constructed.
+type Dynamic interface {
+	// Level is the minimum level logged, which makes a Dynamic a
+	// slog.Leveler.
+	Level() slog.Level
+	// RateLimit returns the requests per minute and burst of a tenant in
+	// a rate limit class: "search", "analytics" or "ai".
+	RateLimit(class string) (perMin, burst int)
+	// DashboardCacheTTL is how long the dashboard and sections of an
+	// analysis stay cached.
+	DashboardCacheTTL() time.Duration
+	// SearchCacheTTL is how long search results stay cached.
+	SearchCacheTTL() time.Duration
+	// JARHeapMB is the JVM heap of a job that does not ask for one.
+	JARHeapMB() int
+	// JARTimeout is how long the JAR may run on one job.
+	JARTimeout() time.Duration
+	// ProgressEveryLines is how many lines of JAR output pass between two
+	// progress updates of a job.
+	ProgressEveryLines() int
+	// ProgressMinInterval is how often an unchanged progress update of a
+	// job is published again.
+	ProgressMinInterval() time.Duration
+}
+
+// setting is a dynamic setting: an integer within bounds, or a string out
+// of choices.
+type setting struct {
+	key         string
+	env         string
+	description string
+	def         any
+	min, max    int
+	choices     []string
+	// startup is the value of the setting in the configuration loaded at
+	// startup, or the zero value when it was not loaded.
+	startup func(c *Config) any
+}
+
+func intSetting(key, env, description string, def, lo, hi int, startup func(c *Config) int) setting {
+	return setting{key: key, env: env, description: description, def: def, min: lo, max: hi,
+		startup: func(c *Config) any { return startup(c) }}
+}
+
+// settings are the dynamic settings, in display order.
+var settings = []setting{
+	{
+		key: "log_level", env: "LOG_LEVEL", description: "Minimum level logged",
+		def: "info", choices: []string{"debug", "info", "warn", "error"},
+		startup: func(c *Config) any { return strings.ToLower(c.LogLevel) },
+	},
+	intSetting("rate_limit.search.per_min", "RATE_LIMIT_SEARCH_PER_MIN", "Search requests per minute of a tenant",
+		60, 1, 1000000, func(c *Config) int { return c.RateLimitSearchPerMin }),
+	intSetting("rate_limit.search.burst", "RATE_LIMIT_SEARCH_BURST", "Search requests a tenant may make at once",
+		20, 1, 100000, func(c *Config) int { return c.RateLimitSearchBurst }),
+	intSetting("rate_limit.analytics.per_min", "RATE_LIMIT_ANALYTICS_PER_MIN", "Analytics requests per minute of a tenant",
+		300, 1, 1000000, func(c *Config) int { return c.RateLimitAnalyticsPerMin }),
+	intSetting("rate_limit.analytics.burst", "RATE_LIMIT_ANALYTICS_BURST", "Analytics requests a tenant may make at once",
+		60, 1, 100000, func(c *Config) int { return c.RateLimitAnalyticsBurst }),
+	intSetting("rate_limit.ai.per_min", "RATE_LIMIT_AI_PER_MIN", "AI requests per minute of a tenant",
+		10, 1, 1000000, func(c *Config) int { return c.RateLimitAIPerMin }),
+	intSetting("rate_limit.ai.burst", "RATE_LIMIT_AI_BURST", "AI requests a tenant may make at once",
+		5, 1, 100000, func(c *Config) int { return c.RateLimitAIBurst }),
+	intSetting("cache.dashboard_ttl_sec", "CACHE_DASHBOARD_TTL_SEC", "Seconds the dashboard and sections of an analysis stay cached",
+		86400, 60, 30*86400, func(c *Config) int { return c.CacheDashboardTTLSec }),
+	intSetting("cache.search_ttl_sec", "CACHE_SEARCH_TTL_SEC", "Seconds search results stay cached",
+		120, 1, 86400, func(c *Config) int { return c.CacheSearchTTLSec }),
+	intSetting("jar.default_heap_mb", "JAR_DEFAULT_HEAP_MB", "JVM heap in MB of a job that does not ask for one",
+		4096, 256, 262144, func(c *Config) int { return c.JARDefaultHeapMB }),
+	intSetting("jar.timeout_sec", "JAR_TIMEOUT_SEC", "Seconds the JAR may run on one job",
+		1800, 60, 7*86400, func(c *Config) int { return c.JARTimeoutSec }),
+	intSetting("progress.every_lines", "PROGRESS_EVERY_LINES", "Lines of JAR output between two progress updates of a job",
+		1000, 1, 10000000, func(c *Config) int { return c.ProgressEveryLines }),
+	intSetting("progress.min_interval_ms", "PROGRESS_MIN_INTERVAL_MS", "Milliseconds before an unchanged progress update of a job is published again",
+		2000, 100, 60000, func(c *Config) int { return c.ProgressMinIntervalMS }),
+}
+
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// startupValue returns the value of s at startup: the configured one, or
+// the default when none was configured.
+func (s setting) startupValue(c *Config) any {
+	v := s.startup(c)
+	if v == "" || v == 0 {
+		return s.def
+	}
+	return v
+}
+
+func (s setting) check(v any) error {
+	switch v := v.(type) {
+	case string:
+		if !slices.Contains(s.choices, v) {
+			return fmt.Errorf("%s must be one of %s, got %q", s.key, strings.Join(s.choices, ", "), v)
+		}
+	case int:
+		if v < s.min || v > s.max {
+			return fmt.Errorf("%s must be between %d and %d, got %d", s.key, s.min, s.max, v)
+		}
+	}
+	return nil
+}
+
+// decode decodes and checks a value of s given as JSON.
+func (s setting) decode(raw json.RawMessage) (any, error) {
+	if s.choices != nil {
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s must be a string", s.key)
+		}
+		return v, s.check(v)
+	}
+	var v int
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("%s must be an integer", s.key)
+	}
+	return v, s.check(v)
+}
+
+// isNull reports whether a setting is given as null, which removes its
+// dynamic value.
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// validateDynamic checks the startup values of the dynamic settings.
+func (c *Config) validateDynamic() error {
+	for _, s := range settings {
+		if err := s.check(s.startupValue(c)); err != nil {
+			return fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+	return nil
+}
+
+// SettingsStore loads the latest stored version of the dynamic settings,
+// version 0 when none is stored. It is implemented by
+// storage.PostgresClient.
+type SettingsStore interface {
+	GetServerSettings(ctx context.Context) (*domain.ServerSettings, error)
+}
+
+// dynamicState is one applied version of the dynamic settings.
+type dynamicState struct {
+	version   int
+	updatedBy string
+	updatedAt *time.Time
+	// overrides are the values set dynamically, by key.
+	overrides map[string]any
+}
+
+// DynamicSettings implements Dynamic over the startup configuration and
+// the stored dynamic settings. It is safe for concurrent use.
+type DynamicSettings struct {
+	// base are the values without dynamic overrides, by key.
+	base    map[string]any
+	sources map[string]string
+	state   atomic.Pointer[dynamicState]
+}
+
+// NewDynamicSettings creates the dynamic settings of the startup
+// configuration c, without dynamic overrides until some are applied.
+func NewDynamicSettings(c *Config) *DynamicSettings {
+	d := &DynamicSettings{base: make(map[string]any), sources: make(map[string]string)}
+	for _, s := range settings {
+		d.base[s.key] = s.startupValue(c)
+		d.sources[s.key] = domain.SettingSourceDefault
+		if os.Getenv(s.env) != "" {
+			d.sources[s.key] = domain.SettingSourceEnv
+		}
+	}
+	d.state.Store(&dynamicState{})
+	return d
+}
+
+// Validate checks the dynamic settings in doc, as given to PUT
+// /api/v1/admin/settings. Unknown keys are rejected, so that a typo is not
+// stored as a setting nothing reads; null is allowed for every known key.
+func (d *DynamicSettings) Validate(doc map[string]json.RawMessage) error {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s, ok := lookupSetting(k)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", k)
+		}
+		if isNull(doc[k]) {
+			continue
+		}
+		if _, err := s.decode(doc[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply makes a stored version of the settings the effective one, and
+// reports whether it did: versions older than the effective one are
+// ignored. A version with an invalid value is refused whole. Keys this
+// release does not know, stored by a newer one, are skipped.
+func (d *DynamicSettings) Apply(s *domain.ServerSettings) (bool, error) {
+	cur := d.state.Load()
+	if s.Version <= cur.version {
+		return false, nil
+	}
+	overrides := make(map[string]any, len(s.Settings))
+	for k, raw := range s.Settings {
+		def, ok := lookupSetting(k)
+		if !ok {
+			slog.Warn("skipping unknown dynamic setting", "key", k, "version", s.Version)
+			continue
+		}
+		if isNull(raw) {
+			continue
+		}
+		v, err := def.decode(raw)
+		if err != nil {
+			return false, fmt.Errorf("settings version %d: %w", s.Version, err)
+		}
+		overrides[k] = v
+	}
+	next := &dynamicState{version: s.Version, updatedBy: s.CreatedBy, updatedAt: s.CreatedAt, overrides: overrides}
+	if !d.state.CompareAndSwap(cur, next) {
+		// Another version was applied meanwhile; apply this one over it
+		// if it is still the newer.
+		return d.Apply(s)
+	}
+	slog.Info("dynamic settings applied", "version", s.Version, "updated_by", s.CreatedBy)
+	return true, nil
+}
+
+// Reload applies the latest stored version of the settings.
+func (d *DynamicSettings) Reload(ctx context.Context, store SettingsStore) error {
+	s, err := store.GetServerSettings(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = d.Apply(s)
+	return err
+}
+
+// Watch keeps the settings at the latest stored version until ctx is done.
+// It reloads them when changes announces a version newer than the
+// effective one, and every interval in case an announcement was missed.
+func (d *DynamicSettings) Watch(ctx context.Context, store SettingsStore, changes <-chan int, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if err := d.Reload(ctx, store); err != nil && ctx.Err() == nil {
+			slog.Warn("failed to reload dynamic settings", "error", err)
+		}
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-changes:
+				if !ok {
+					changes = nil
+					continue
+				}
+				if v > d.Version() {
+					break wait
+				}
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
+
+// Version returns the effective version of the stored settings, 0 before
+// any was applied.
+func (d *DynamicSettings) Version() int {
+	return d.state.Load().version
+}
+
+// Effective returns every setting with its effective value and source.
+func (d *DynamicSettings) Effective() *domain.EffectiveSettings {
+	st := d.state.Load()
+	out := &domain.EffectiveSettings{
+		Version:   st.version,
+		UpdatedBy: st.updatedBy,
+		UpdatedAt: st.updatedAt,
+		Settings:  make([]domain.EffectiveSetting, 0, len(settings)),
+	}
+	for _, s := range settings {
+		e := domain.EffectiveSetting{
+			Key:         s.key,
+			Value:       d.base[s.key],
+			Source:      d.sources[s.key],
+			Default:     d.base[s.key],
+			Env:         s.env,
+			Description: s.description,
+		}
+		if v, ok := st.overrides[s.key]; ok {
+			e.Value, e.Source = v, domain.SettingSourceDynamic
+		}
+		out.Settings = append(out.Settings, e)
+	}
+	return out
+}
+
+func (d *DynamicSettings) value(key string) any {
+	if v, ok := d.state.Load().overrides[key]; ok {
+		return v
+	}
+	return d.base[key]
+}
+
+func (d *DynamicSettings) intValue(key string) int {
+	v, _ := d.value(key).(int)
+	return v
+}
+
+func (d *DynamicSettings) Level() slog.Level {
+	switch d.value("log_level") {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func (d *DynamicSettings) RateLimit(class string) (perMin, burst int) {
+	return d.intValue("rate_limit." + class + ".per_min"), d.intValue("rate_limit." + class + ".burst")
+}
+
+func (d *DynamicSettings) DashboardCacheTTL() time.Duration {
+	return time.Duration(d.intValue("cache.dashboard_ttl_sec")) * time.Second
+}
+
+func (d *DynamicSettings) SearchCacheTTL() time.Duration {
+	return time.Duration(d.intValue("cache.search_ttl_sec")) * time.Second
+}
+
+func (d *DynamicSettings) JARHeapMB() int {
+	return d.intValue("jar.default_heap_mb")
+}
+
+func (d *DynamicSettings) JARTimeout() time.Duration {
+	return time.Duration(d.intValue("jar.timeout_sec")) * time.Second
+}
+
+func (d *DynamicSettings) ProgressEveryLines() int {
+	return d.intValue("progress.every_lines")
+}
+
+func (d *DynamicSettings) ProgressMinInterval() time.Duration {
+	return time.Duration(d.intValue("progress.min_interval_ms")) * time.Millisecond
+}
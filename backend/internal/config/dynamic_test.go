@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+func rawSettings(kv map[string]string) map[string]json.RawMessage {
+	out := make(map[string]json.RawMessage, len(kv))
+	for k, v := range kv {
+		out[k] = json.RawMessage(v)
+	}
+	return out
+}
+
+func TestDynamicSettings_Sources(t *testing.T) {
+	t.Setenv("RATE_LIMIT_AI_PER_MIN", "30")
+	d := NewDynamicSettings(&Config{RateLimitAIPerMin: 30, LogLevel: "WARN"})
+
+	perMin, burst := d.RateLimit("ai")
+	assert.Equal(t, 30, perMin)
+	assert.Equal(t, 5, burst, "unset values take the default")
+	assert.Equal(t, slog.LevelWarn, d.Level())
+	assert.Equal(t, 24*time.Hour, d.DashboardCacheTTL())
+
+	_, err := d.Apply(&domain.ServerSettings{Version: 1, Settings: rawSettings(map[string]string{
+		"cache.search_ttl_sec": "30",
+	})})
+	require.NoError(t, err)
+
+	sources := map[string]string{}
+	for _, s := range d.Effective().Settings {
+		sources[s.Key] = s.Source
+	}
+	assert.Equal(t, domain.SettingSourceEnv, sources["rate_limit.ai.per_min"])
+	assert.Equal(t, domain.SettingSourceDefault, sources["rate_limit.ai.burst"])
+	assert.Equal(t, domain.SettingSourceDynamic, sources["cache.search_ttl_sec"])
+	assert.Equal(t, 30*time.Second, d.SearchCacheTTL())
+}
+
+func TestDynamicSettings_Validate(t *testing.T) {
+	d := NewDynamicSettings(&Config{})
+	tests := []struct {
+		name    string
+		doc     map[string]string
+		wantErr string
+	}{
+		{name: "valid", doc: map[string]string{"log_level": `"debug"`, "jar.timeout_sec": "600"}},
+		{name: "null removes", doc: map[string]string{"jar.timeout_sec": "null"}},
+		{name: "unknown key", doc: map[string]string{"jar.timeout": "600"}, wantErr: `unknown setting "jar.timeout"`},
+		{name: "unknown null key", doc: map[string]string{"cache.ttl": "null"}, wantErr: `unknown setting "cache.ttl"`},
+		{name: "below bound", doc: map[string]string{"progress.every_lines": "0"}, wantErr: "progress.every_lines must be between 1 and 10000000, got 0"},
+		{name: "not an integer", doc: map[string]string{"jar.timeout_sec": "1.5"}, wantErr: "jar.timeout_sec must be an integer"},
+		{name: "not a string", doc: map[string]string{"log_level": "1"}, wantErr: "log_level must be a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.Validate(rawSettings(tt.doc))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDynamicSettings_Apply(t *testing.T) {
+	d := NewDynamicSettings(&Config{})
+
+	applied, err := d.Apply(&domain.ServerSettings{Version: 2, Settings: rawSettings(map[string]string{
+		"jar.default_heap_mb": "8192",
+		"jar.newer_knob":      "1",
+	})})
+	require.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, 8192, d.JARHeapMB(), "keys of a newer release are skipped, not fatal")
+
+	applied, err = d.Apply(&domain.ServerSettings{Version: 1, Settings: rawSettings(map[string]string{
+		"jar.default_heap_mb": "2048",
+	})})
+	require.NoError(t, err)
+	assert.False(t, applied, "older versions are ignored")
+	assert.Equal(t, 8192, d.JARHeapMB())
+
+	_, err = d.Apply(&domain.ServerSettings{Version: 3, Settings: rawSettings(map[string]string{
+		"jar.default_heap_mb": "1024",
+		"jar.timeout_sec":     "1",
+	})})
+	require.Error(t, err)
+	assert.Equal(t, 2, d.Version(), "an invalid version is refused whole")
+	assert.Equal(t, 8192, d.JARHeapMB())
+}
+
+func TestLoad_Validate_DynamicSettings(t *testing.T) {
+	cfg := &Config{
+		PostgresURL:   "postgres://localhost:5432/db",
+		ClickHouseURL: "clickhouse://localhost:9004/db",
+		NATSURL:       "nats://localhost:4222",
+	}
+	require.NoError(t, cfg.validate(), "zero values take the defaults")
+
+	cfg.LogLevel = "verbose"
+	assert.EqualError(t, cfg.validate(), `LOG_LEVEL: log_level must be one of debug, info, warn, error, got "verbose"`)
+
+	cfg.LogLevel = "Debug"
+	cfg.JARTimeoutSec = 5
+	assert.EqualError(t, cfg.validate(), "JAR_TIMEOUT_SEC: jar.timeout_sec must be between 60 and 604800, got 5")
+}
+
+// memSettingsStore is a SettingsStore shared by the replicas of a test, as
+// Postgres would be.
+type memSettingsStore struct {
+	mu     sync.Mutex
+	latest domain.ServerSettings
+}
+
+func (m *memSettingsStore) GetServerSettings(ctx context.Context) (*domain.ServerSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.latest
+	return &s, nil
+}
+
+func (m *memSettingsStore) store(s domain.ServerSettings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latest = s
+}
+
+// TestDynamicSettings_Propagation runs two replicas against one Redis and
+// one store, and checks that a change stored and announced by one is picked
+// up by the other without waiting for its poll.
+func TestDynamicSettings_Propagation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &memSettingsStore{}
+
+	replica := func() (*DynamicSettings, *storage.RedisClient) {
+		rc, err := storage.NewRedisClient(ctx, "redis://"+mr.Addr())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = rc.Close() })
+		changes, err := rc.SubscribeSettingsChanges(ctx)
+		require.NoError(t, err)
+		d := NewDynamicSettings(&Config{})
+		go d.Watch(ctx, store, changes, time.Hour)
+		return d, rc
+	}
+	a, aRedis := replica()
+	b, _ := replica()
+
+	change := domain.ServerSettings{Version: 1, CreatedBy: "admin-1", Settings: rawSettings(map[string]string{
+		"log_level":               `"error"`,
+		"rate_limit.search.burst": "50",
+	})}
+	store.store(change)
+	_, err := a.Apply(&change)
+	require.NoError(t, err)
+	require.NoError(t, aRedis.PublishSettingsChanged(ctx, change.Version))
+
+	require.Eventually(t, func() bool { return b.Version() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, slog.LevelError, b.Level())
+	_, burst := b.RateLimit("search")
+	assert.Equal(t, 50, burst)
+	assert.Equal(t, "admin-1", b.Effective().UpdatedBy)
+}
@@ -0,0 +1,449 @@
+// Package diagnostics assembles the diagnostic bundle of an analysis: what
+// support needs to investigate a failed or suspicious job, gathered in one
+// request instead of several round trips. Each section comes from its own
+// source and degrades on its own: a source that fails leaves its section
+// reading "unavailable: <reason>" and the rest of the bundle intact.
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+const (
+	// DefaultMaxBytes caps the encoded bundle. Sections that would take it
+	// past the cap are left out, last ones first.
+	DefaultMaxBytes = 8 << 20
+
+	// DefaultSourceTimeout bounds the time one source may take.
+	DefaultSourceTimeout = 10 * time.Second
+
+	// DefaultSlowQueryThreshold is the duration from which a ClickHouse
+	// statement is reported as slow.
+	DefaultSlowQueryThreshold = time.Second
+
+	// DefaultSlowQueryWindow is how far back the query log is read.
+	DefaultSlowQueryWindow = 7 * 24 * time.Hour
+
+	// maxStderrBytes caps the JAR stderr kept in the bundle; its tail is
+	// kept, where the JVM reports why it stopped.
+	maxStderrBytes = 64 << 10
+
+	// maxSlowQueries caps the slow statements listed.
+	maxSlowQueries = 20
+
+	redacted = "[redacted]"
+)
+
+// ErrJobNotFound is returned by Assemble for a job the tenant does not have.
+var ErrJobNotFound = errors.New("diagnostics: analysis job not found")
+
+// JobStore reads the job record and its event log.
+type JobStore interface {
+	GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, error)
+	ListJobEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JobEvent, error)
+}
+
+// EntryStats reads what ClickHouse holds for a job.
+// storage.ClickHouseClient implements it.
+type EntryStats interface {
+	JobEntryStats(ctx context.Context, tenantID, jobID string) ([]domain.LogTypeStats, error)
+}
+
+// SlowQueryLog lists the slow ClickHouse statements naming a job.
+// storage.ClickHouseClient implements it.
+type SlowQueryLog interface {
+	ListJobSlowQueries(ctx context.Context, jobID string, since time.Time, minDuration time.Duration, limit int) ([]domain.SlowQuery, error)
+}
+
+// CacheInspector lists the Redis keys caching data of a job.
+// storage.RedisClient implements it.
+type CacheInspector interface {
+	JobCacheKeys(ctx context.Context, tenantID, jobID string) ([]domain.CachedKey, error)
+}
+
+// ObjectChecker tells whether an object is stored.
+type ObjectChecker interface {
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// SettingsSource reports the dynamic server settings in effect.
+// config.DynamicSettings implements it.
+type SettingsSource interface {
+	Effective() *domain.EffectiveSettings
+}
+
+// Options configures an Assembler. Zero values select the defaults.
+type Options struct {
+	MaxBytes           int
+	SourceTimeout      time.Duration
+	SlowQueryThreshold time.Duration
+	SlowQueryWindow    time.Duration
+
+	// Config holds the static configuration values that bear on how jobs
+	// are processed, by environment variable. Values whose name suggests a
+	// secret, and credentials within URLs, are redacted.
+	Config map[string]any
+}
+
+// Assembler builds diagnostic bundles. Every source but jobs may be nil,
+// in which case its section reads unavailable.
+type Assembler struct {
+	jobs     JobStore
+	entries  EntryStats
+	queries  SlowQueryLog
+	cache    CacheInspector
+	objects  ObjectChecker
+	settings SettingsSource
+	opts     Options
+
+	now func() time.Time
+}
+
+// NewAssembler creates an Assembler over the given sources.
+func NewAssembler(jobs JobStore, entries EntryStats, queries SlowQueryLog, cache CacheInspector, objects ObjectChecker, settings SettingsSource, opts Options) *Assembler {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.SourceTimeout <= 0 {
+		opts.SourceTimeout = DefaultSourceTimeout
+	}
+	if opts.SlowQueryThreshold <= 0 {
+		opts.SlowQueryThreshold = DefaultSlowQueryThreshold
+	}
+	if opts.SlowQueryWindow <= 0 {
+		opts.SlowQueryWindow = DefaultSlowQueryWindow
+	}
+	return &Assembler{
+		jobs: jobs, entries: entries, queries: queries, cache: cache, objects: objects, settings: settings,
+		opts: opts, now: time.Now,
+	}
+}
+
+// Bundle is the diagnostic bundle of one analysis. Each section holds its
+// data, or the string "unavailable: <reason>". Omitted names the sections
+// left out to keep the bundle under its size cap.
+type Bundle struct {
+	JobID       uuid.UUID       `json:"job_id"`
+	TenantID    uuid.UUID       `json:"tenant_id"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Job         json.RawMessage `json:"job"`
+	Events      json.RawMessage `json:"events"`
+	Execution   json.RawMessage `json:"execution"`
+	Ingestion   json.RawMessage `json:"ingestion"`
+	Entries     json.RawMessage `json:"entries"`
+	Cache       json.RawMessage `json:"cache"`
+	SlowQueries json.RawMessage `json:"slow_queries"`
+	Config      json.RawMessage `json:"config"`
+	Omitted     []string        `json:"omitted,omitempty"`
+}
+
+// section is a section of a bundle being built: build reads its data and
+// the encoded result goes to field.
+type section struct {
+	name  string
+	field *json.RawMessage
+	build func() (any, error)
+}
+
+// jobSection is the job record with the status changes its event log
+// records. The JAR stderr is in the execution section.
+type jobSection struct {
+	Record        *domain.AnalysisJob `json:"record"`
+	StatusHistory []statusChange      `json:"status_history,omitempty"`
+}
+
+type statusChange struct {
+	Kind       domain.JobEventKind `json:"kind"`
+	Message    string              `json:"message,omitempty"`
+	OccurredAt time.Time           `json:"occurred_at"`
+}
+
+// executionSection is how the JAR ran.
+type executionSection struct {
+	ErrorMessage    *string           `json:"error_message,omitempty"`
+	ErrorCode       *string           `json:"error_code,omitempty"`
+	JARStderr       string            `json:"jar_stderr,omitempty"`
+	StderrTruncated bool              `json:"jar_stderr_truncated,omitempty"`
+	PeakRSSKB       *int64            `json:"peak_rss_kb,omitempty"`
+	CPUTimeMS       *int64            `json:"cpu_time_ms,omitempty"`
+	WallTimeMS      *int64            `json:"wall_time_ms,omitempty"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	CompletedAt     *time.Time        `json:"completed_at,omitempty"`
+	LogFormat       *domain.LogFormat `json:"log_format,omitempty"`
+	JARFlags        domain.JARFlags   `json:"jar_flags"`
+	StoredOutput    any               `json:"stored_jar_output"`
+}
+
+// ingestionSection is what the worker ingested and how it compares with
+// what the JAR reported.
+type ingestionSection struct {
+	TotalLines       *int64                          `json:"total_lines,omitempty"`
+	ProcessedLines   *int64                          `json:"processed_lines,omitempty"`
+	APICount         *int64                          `json:"api_count,omitempty"`
+	SQLCount         *int64                          `json:"sql_count,omitempty"`
+	FilterCount      *int64                          `json:"filter_count,omitempty"`
+	EscCount         *int64                          `json:"esc_count,omitempty"`
+	IngestionFilters []domain.IngestionFilterMatch   `json:"ingestion_filters,omitempty"`
+	DataQuality      *domain.DataQuality             `json:"data_quality,omitempty"`
+	Reconciliation   *domain.IngestionReconciliation `json:"reconciliation,omitempty"`
+	Sampling         *domain.Sampling                `json:"sampling,omitempty"`
+	Integrity        *domain.FileIntegrity           `json:"integrity,omitempty"`
+	ClockSkew        *domain.ClockSkewReport         `json:"clock_skew,omitempty"`
+}
+
+// configSection is the configuration that bore on the job: its own
+// settings, the dynamic server settings and the static configuration of
+// the API process, which the worker usually shares.
+type configSection struct {
+	Job struct {
+		JVMHeapMB      int              `json:"jvm_heap_mb"`
+		TimeoutSeconds int              `json:"timeout_seconds"`
+		Sampling       *domain.Sampling `json:"sampling,omitempty"`
+		JARFlags       domain.JARFlags  `json:"jar_flags"`
+	} `json:"job"`
+	Settings any            `json:"settings"`
+	Server   map[string]any `json:"server,omitempty"`
+}
+
+// Assemble builds the bundle of a job. It fails only when the job does not
+// exist; any other failure leaves the sections of its source unavailable.
+func (a *Assembler) Assemble(ctx context.Context, tenantID, jobID uuid.UUID) (*Bundle, error) {
+	tid, jid := tenantID.String(), jobID.String()
+	ctx = storage.WithTenant(ctx, tid)
+
+	var job *domain.AnalysisJob
+	jobErr := a.source(ctx, func(ctx context.Context) (err error) {
+		job, err = a.jobs.GetJob(ctx, tenantID, jobID)
+		return err
+	})
+	if jobErr != nil && storage.IsNotFound(jobErr) {
+		return nil, ErrJobNotFound
+	}
+
+	var events []domain.JobEvent
+	eventsErr := a.source(ctx, func(ctx context.Context) (err error) {
+		events, err = a.jobs.ListJobEvents(ctx, tenantID, jobID)
+		return err
+	})
+
+	var sections []section
+	b := &Bundle{JobID: jobID, TenantID: tenantID, GeneratedAt: a.now().UTC()}
+	add := func(name string, field *json.RawMessage, build func() (any, error)) {
+		sections = append(sections, section{name, field, build})
+	}
+	fromJob := func(build func(*domain.AnalysisJob) any) func() (any, error) {
+		return func() (any, error) {
+			if jobErr != nil {
+				return nil, fmt.Errorf("job record: %w", jobErr)
+			}
+			return build(job), nil
+		}
+	}
+
+	add("job", &b.Job, fromJob(func(job *domain.AnalysisJob) any {
+		record := *job
+		record.JARStderr = nil
+		s := jobSection{Record: &record}
+		if eventsErr == nil {
+			s.StatusHistory = statusHistory(events)
+		}
+		return s
+	}))
+	add("events", &b.Events, func() (any, error) {
+		if eventsErr != nil {
+			return nil, eventsErr
+		}
+		return jobevents.Timeline(events), nil
+	})
+	add("execution", &b.Execution, fromJob(func(job *domain.AnalysisJob) any {
+		return a.execution(ctx, job)
+	}))
+	add("ingestion", &b.Ingestion, fromJob(func(job *domain.AnalysisJob) any {
+		return ingestionSection{
+			TotalLines: job.TotalLines, ProcessedLines: job.ProcessedLines,
+			APICount: job.APICount, SQLCount: job.SQLCount, FilterCount: job.FilterCount, EscCount: job.EscCount,
+			IngestionFilters: job.IngestionFilters, DataQuality: job.DataQuality, Reconciliation: job.Reconciliation,
+			Sampling: job.Sampling, Integrity: job.Integrity, ClockSkew: job.ClockSkew,
+		}
+	}))
+	add("entries", &b.Entries, func() (stats any, err error) {
+		if a.entries == nil {
+			return nil, errors.New("no ClickHouse client")
+		}
+		err = a.source(ctx, func(ctx context.Context) (err error) {
+			stats, err = a.entries.JobEntryStats(ctx, tid, jid)
+			return err
+		})
+		return stats, err
+	})
+	add("cache", &b.Cache, func() (keys any, err error) {
+		if a.cache == nil {
+			return nil, errors.New("no Redis client")
+		}
+		err = a.source(ctx, func(ctx context.Context) (err error) {
+			keys, err = a.cache.JobCacheKeys(ctx, tid, jid)
+			return err
+		})
+		return keys, err
+	})
+	add("slow_queries", &b.SlowQueries, func() (queries any, err error) {
+		if a.queries == nil {
+			return nil, errors.New("no ClickHouse client")
+		}
+		since := a.now().Add(-a.opts.SlowQueryWindow)
+		err = a.source(ctx, func(ctx context.Context) (err error) {
+			queries, err = a.queries.ListJobSlowQueries(ctx, jid, since, a.opts.SlowQueryThreshold, maxSlowQueries)
+			return err
+		})
+		return queries, err
+	})
+	add("config", &b.Config, fromJob(func(job *domain.AnalysisJob) any {
+		return a.config(job)
+	}))
+
+	// The header and the section names take a few hundred bytes; the rest
+	// of the cap is shared by the sections in order.
+	budget := a.opts.MaxBytes - 512
+	for _, s := range sections {
+		v, err := s.build()
+		data, encErr := json.Marshal(v)
+		switch {
+		case err != nil:
+			data = unavailable(err.Error())
+		case encErr != nil:
+			data = unavailable("encode: " + encErr.Error())
+		}
+		if len(data) > budget {
+			data = unavailable(fmt.Sprintf("omitted to keep the bundle under %d bytes", a.opts.MaxBytes))
+			b.Omitted = append(b.Omitted, s.name)
+		}
+		budget -= len(data)
+		*s.field = data
+	}
+	return b, nil
+}
+
+// source runs one read of a source within the source timeout.
+func (a *Assembler) source(ctx context.Context, read func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, a.opts.SourceTimeout)
+	defer cancel()
+	return read(ctx)
+}
+
+// execution builds the execution section. Whether the JAR output was kept
+// is checked in object storage and reads unavailable on its own.
+func (a *Assembler) execution(ctx context.Context, job *domain.AnalysisJob) executionSection {
+	s := executionSection{
+		ErrorMessage: job.ErrorMessage, ErrorCode: job.ErrorCode,
+		PeakRSSKB: job.PeakRSSKB, CPUTimeMS: job.CPUTimeMS, WallTimeMS: job.WallTimeMS,
+		StartedAt: job.StartedAt, CompletedAt: job.CompletedAt,
+		LogFormat: job.LogFormat, JARFlags: job.JARFlags,
+	}
+	if job.JARStderr != nil {
+		s.JARStderr = *job.JARStderr
+		if len(s.JARStderr) > maxStderrBytes {
+			s.JARStderr = s.JARStderr[len(s.JARStderr)-maxStderrBytes:]
+			s.StderrTruncated = true
+		}
+	}
+
+	if a.objects == nil {
+		s.StoredOutput = "unavailable: no object storage"
+		return s
+	}
+	var stored bool
+	err := a.source(ctx, func(ctx context.Context) (err error) {
+		stored, err = a.objects.Exists(ctx, worker.JAROutputKey(job.TenantID.String(), job.ID.String()))
+		return err
+	})
+	if err != nil {
+		s.StoredOutput = "unavailable: " + err.Error()
+	} else {
+		s.StoredOutput = stored
+	}
+	return s
+}
+
+// config builds the config section, redacting secrets.
+func (a *Assembler) config(job *domain.AnalysisJob) configSection {
+	var s configSection
+	s.Job.JVMHeapMB = job.JVMHeapMB
+	s.Job.TimeoutSeconds = job.TimeoutSeconds
+	s.Job.Sampling = job.Sampling
+	s.Job.JARFlags = job.JARFlags
+
+	if a.settings == nil {
+		s.Settings = "unavailable: no dynamic settings"
+	} else {
+		settings := a.settings.Effective().Settings
+		out := make([]domain.EffectiveSetting, len(settings))
+		for i, st := range settings {
+			st.Value = redact(st.Env, st.Value)
+			st.Default = redact(st.Env, st.Default)
+			out[i] = st
+		}
+		s.Settings = out
+	}
+
+	if len(a.opts.Config) > 0 {
+		s.Server = make(map[string]any, len(a.opts.Config))
+		for k, v := range a.opts.Config {
+			s.Server[k] = redact(k, v)
+		}
+	}
+	return s
+}
+
+// statusHistory picks the events of a job that change its status.
+func statusHistory(events []domain.JobEvent) []statusChange {
+	var out []statusChange
+	for _, e := range jobevents.Timeline(events) {
+		switch e.Kind {
+		case domain.JobEventCreated, domain.JobEventRetry, domain.JobEventCompleted, domain.JobEventFailed:
+			out = append(out, statusChange{Kind: e.Kind, Message: e.Message, OccurredAt: e.OccurredAt})
+		}
+	}
+	return out
+}
+
+// secretNames are the parts of a setting name that mark its value secret.
+var secretNames = []string{"SECRET", "PASSWORD", "TOKEN", "API_KEY", "ACCESS_KEY", "PRIVATE", "CREDENTIAL"}
+
+// redact hides the value of a setting named like a secret, and the
+// password of a URL value.
+func redact(name string, v any) any {
+	upper := strings.ToUpper(name)
+	for _, s := range secretNames {
+		if strings.Contains(upper, s) {
+			if v == nil || v == "" {
+				return v
+			}
+			return redacted
+		}
+	}
+	if s, ok := v.(string); ok && strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	}
+	return v
+}
+
+// unavailable encodes the placeholder of a section that could not be read.
+func unavailable(reason string) json.RawMessage {
+	data, _ := json.Marshal("unavailable: " + reason)
+	return data
+}
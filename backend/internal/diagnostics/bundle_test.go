@@ -0,0 +1,238 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var (
+	tenantID = uuid.MustParse("00000000-0000-4000-8000-000000000001")
+	jobID    = uuid.MustParse("00000000-0000-4000-8000-000000000002")
+	errDown  = errors.New("connection refused")
+)
+
+// fakeSources implements every source of an Assembler; a set error makes
+// its source fail.
+type fakeSources struct {
+	jobErr, eventsErr, entriesErr, queriesErr, cacheErr, objectsErr error
+	stderr                                                          string
+}
+
+func (f *fakeSources) GetJob(_ context.Context, tid, jid uuid.UUID) (*domain.AnalysisJob, error) {
+	if f.jobErr != nil {
+		return nil, f.jobErr
+	}
+	msg := "jar runner: non-zero exit code 1: OutOfMemoryError"
+	return &domain.AnalysisJob{
+		ID: jid, TenantID: tid, Status: domain.JobStatusFailed, JVMHeapMB: 4096, TimeoutSeconds: 1800,
+		ErrorMessage: &msg, JARStderr: &f.stderr,
+		Sampling: &domain.Sampling{Rate: 10, SlowThresholdMS: 1000},
+	}, nil
+}
+
+func (f *fakeSources) ListJobEvents(_ context.Context, tid, jid uuid.UUID) ([]domain.JobEvent, error) {
+	if f.eventsErr != nil {
+		return nil, f.eventsErr
+	}
+	t0 := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	return []domain.JobEvent{
+		{ID: 1, TenantID: tid, JobID: jid, Kind: domain.JobEventCreated, OccurredAt: t0},
+		{ID: 2, TenantID: tid, JobID: jid, Kind: domain.JobEventStageStarted, Stage: "jar", OccurredAt: t0.Add(time.Second)},
+		{ID: 3, TenantID: tid, JobID: jid, Kind: domain.JobEventFailed, Message: "jar failed", OccurredAt: t0.Add(time.Minute)},
+	}, nil
+}
+
+func (f *fakeSources) JobEntryStats(context.Context, string, string) ([]domain.LogTypeStats, error) {
+	if f.entriesErr != nil {
+		return nil, f.entriesErr
+	}
+	return []domain.LogTypeStats{{LogType: domain.LogTypeAPI, Rows: 10, Entries: 100}}, nil
+}
+
+func (f *fakeSources) ListJobSlowQueries(_ context.Context, _ string, _ time.Time, minDuration time.Duration, _ int) ([]domain.SlowQuery, error) {
+	if f.queriesErr != nil {
+		return nil, f.queriesErr
+	}
+	return []domain.SlowQuery{{DurationMS: minDuration.Milliseconds() * 3, Query: "SELECT 1"}}, nil
+}
+
+func (f *fakeSources) JobCacheKeys(context.Context, string, string) ([]domain.CachedKey, error) {
+	if f.cacheErr != nil {
+		return nil, f.cacheErr
+	}
+	return []domain.CachedKey{{Key: "remedyiq:t:dashboard:j", Bytes: 42, TTLSeconds: 60}}, nil
+}
+
+func (f *fakeSources) Exists(context.Context, string) (bool, error) {
+	return f.objectsErr == nil, f.objectsErr
+}
+
+func (f *fakeSources) Effective() *domain.EffectiveSettings {
+	return &domain.EffectiveSettings{Settings: []domain.EffectiveSetting{
+		{Key: "jar.heap_mb", Env: "JAR_DEFAULT_HEAP_MB", Value: 4096, Default: 4096},
+	}}
+}
+
+func newTestAssembler(f *fakeSources, opts Options) *Assembler {
+	a := NewAssembler(f, f, f, f, f, f, opts)
+	a.now = func() time.Time { return time.Date(2026, 5, 2, 0, 0, 0, 0, time.UTC) }
+	return a
+}
+
+// decodeBundle returns the sections of a bundle by name, re-encoded.
+func decodeBundle(t *testing.T, b *Bundle) map[string]json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	var out map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &out))
+	return out
+}
+
+// unavailableReason returns the reason of an unavailable section, or ""
+// for a section with data.
+func unavailableReason(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.HasPrefix(s, "unavailable: ") {
+		return ""
+	}
+	return strings.TrimPrefix(s, "unavailable: ")
+}
+
+var bundleSections = []string{"job", "events", "execution", "ingestion", "entries", "cache", "slow_queries", "config"}
+
+func TestAssemble_AllSources(t *testing.T) {
+	a := newTestAssembler(&fakeSources{stderr: "Exception in thread main"}, Options{
+		Config: map[string]any{
+			"JAR_PATH":              "/opt/jar/ARLogAnalyzer.jar",
+			"CLICKHOUSE_URL":        "clickhouse://default:hunter2@ch:9000/remedyiq",
+			"S3_SECRET_KEY":         "minioadmin",
+			"ANTHROPIC_API_KEY":     "",
+			"WORKER_HEAP_BUDGET_MB": 16384,
+		},
+	})
+	b, err := a.Assemble(context.Background(), tenantID, jobID)
+	require.NoError(t, err)
+	sections := decodeBundle(t, b)
+	for _, name := range bundleSections {
+		assert.Empty(t, unavailableReason(sections[name]), name)
+	}
+	assert.Empty(t, b.Omitted)
+
+	var job jobSection
+	require.NoError(t, json.Unmarshal(sections["job"], &job))
+	assert.Nil(t, job.Record.JARStderr, "the stderr is in the execution section")
+	require.Len(t, job.StatusHistory, 2)
+	assert.Equal(t, domain.JobEventFailed, job.StatusHistory[1].Kind)
+
+	var exec map[string]any
+	require.NoError(t, json.Unmarshal(sections["execution"], &exec))
+	assert.Equal(t, "Exception in thread main", exec["jar_stderr"])
+	assert.Equal(t, true, exec["stored_jar_output"])
+
+	var cfg struct {
+		Job      map[string]any   `json:"job"`
+		Settings []map[string]any `json:"settings"`
+		Server   map[string]any   `json:"server"`
+	}
+	require.NoError(t, json.Unmarshal(sections["config"], &cfg))
+	assert.EqualValues(t, 4096, cfg.Job["jvm_heap_mb"])
+	assert.NotNil(t, cfg.Job["sampling"])
+	assert.Len(t, cfg.Settings, 1)
+	assert.Equal(t, "/opt/jar/ARLogAnalyzer.jar", cfg.Server["JAR_PATH"])
+	assert.Equal(t, "[redacted]", cfg.Server["S3_SECRET_KEY"])
+	assert.Equal(t, "", cfg.Server["ANTHROPIC_API_KEY"], "unset secrets show as unset")
+	assert.Equal(t, "clickhouse://default:xxxxx@ch:9000/remedyiq", cfg.Server["CLICKHOUSE_URL"])
+	assert.NotContains(t, string(sections["config"]), "hunter2")
+	assert.NotContains(t, string(sections["config"]), "minioadmin")
+
+	var queries []domain.SlowQuery
+	require.NoError(t, json.Unmarshal(sections["slow_queries"], &queries))
+	require.Len(t, queries, 1)
+	assert.EqualValues(t, 3000, queries[0].DurationMS, "the default threshold is one second")
+}
+
+func TestAssemble_EachSourceFailing(t *testing.T) {
+	tests := []struct {
+		name        string
+		sources     fakeSources
+		unavailable []string
+	}{
+		{"job record", fakeSources{jobErr: errDown}, []string{"job", "execution", "ingestion", "config"}},
+		{"job events", fakeSources{eventsErr: errDown}, []string{"events"}},
+		{"clickhouse entries", fakeSources{entriesErr: errDown}, []string{"entries"}},
+		{"query log", fakeSources{queriesErr: errDown}, []string{"slow_queries"}},
+		{"redis", fakeSources{cacheErr: errDown}, []string{"cache"}},
+		{"object storage", fakeSources{objectsErr: errDown}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newTestAssembler(&tt.sources, Options{}).Assemble(context.Background(), tenantID, jobID)
+			require.NoError(t, err, "a failing source never fails the bundle")
+			sections := decodeBundle(t, b)
+			for _, name := range bundleSections {
+				reason := unavailableReason(sections[name])
+				if slices.Contains(tt.unavailable, name) {
+					assert.Contains(t, reason, "connection refused", name)
+				} else {
+					assert.Empty(t, reason, name)
+				}
+			}
+		})
+	}
+
+	t.Run("stored output check", func(t *testing.T) {
+		b, err := newTestAssembler(&fakeSources{objectsErr: errDown}, Options{}).Assemble(context.Background(), tenantID, jobID)
+		require.NoError(t, err)
+		var exec map[string]any
+		require.NoError(t, json.Unmarshal(b.Execution, &exec))
+		assert.Equal(t, "unavailable: connection refused", exec["stored_jar_output"])
+	})
+
+	t.Run("missing sources", func(t *testing.T) {
+		a := NewAssembler(&fakeSources{}, nil, nil, nil, nil, nil, Options{})
+		b, err := a.Assemble(context.Background(), tenantID, jobID)
+		require.NoError(t, err)
+		sections := decodeBundle(t, b)
+		for _, name := range []string{"entries", "cache", "slow_queries"} {
+			assert.NotEmpty(t, unavailableReason(sections[name]), name)
+		}
+		assert.Empty(t, unavailableReason(sections["job"]))
+	})
+}
+
+func TestAssemble_JobNotFound(t *testing.T) {
+	a := newTestAssembler(&fakeSources{jobErr: errors.New("postgres: get job: not found")}, Options{})
+	_, err := a.Assemble(context.Background(), tenantID, jobID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestAssemble_SizeCap(t *testing.T) {
+	f := &fakeSources{stderr: strings.Repeat("at com.bmc.arsys.Analyzer.run\n", 10000)}
+	a := newTestAssembler(f, Options{MaxBytes: 32 << 10})
+	b, err := a.Assemble(context.Background(), tenantID, jobID)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 32<<10)
+	assert.Equal(t, []string{"execution"}, b.Omitted, "only the section over the cap is left out")
+	assert.Contains(t, unavailableReason(b.Execution), "under 32768 bytes")
+	assert.Empty(t, unavailableReason(b.Config))
+
+	exec := NewAssembler(f, nil, nil, nil, f, nil, Options{}).execution(context.Background(), &domain.AnalysisJob{JARStderr: &f.stderr})
+	assert.True(t, exec.StderrTruncated)
+	assert.Len(t, exec.JARStderr, maxStderrBytes)
+	assert.True(t, strings.HasSuffix(f.stderr, exec.JARStderr), "the tail of the stderr is kept")
+}
@@ -15,13 +15,22 @@ type HistogramCounts struct {
 }
 
 type HistogramBucket struct {
-	Timestamp time.Time       `json:"timestamp"`
+	Timestamp Timestamp       `json:"timestamp"`
 	Counts    HistogramCounts `json:"counts"`
 }
 
 type HistogramResponse struct {
 	Buckets    []HistogramBucket `json:"buckets"`
 	BucketSize string            `json:"bucket_size"`
+
+	Estimate
+}
+
+// SortKey is a column search results are ordered by, with its direction,
+// "asc" or "desc".
+type SortKey struct {
+	Field string `json:"field"`
+	Order string `json:"order"`
 }
 
 type ContextEntry struct {
@@ -52,6 +61,32 @@ type AutocompleteResponse struct {
 	IsField bool                `json:"is_field"`
 }
 
+// VocabularyFields are the entry fields whose values are kept in the tenant
+// vocabulary, for typeahead outside a single analysis.
+var VocabularyFields = []string{"form", "filter_name", "sql_table", "queue", "esc_name"}
+
+// IsVocabularyField reports whether field is kept in the tenant vocabulary.
+func IsVocabularyField(field string) bool {
+	for _, f := range VocabularyFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// VocabularyTerm is a value of a vocabulary field seen in the analyses of a
+// tenant. Occurrences counts entries over every analysis that held it.
+type VocabularyTerm struct {
+	Field       string    `json:"field" db:"field"`
+	Value       string    `json:"value" db:"value"`
+	Occurrences int64     `json:"occurrences" db:"occurrences"`
+	JobCount    int64     `json:"job_count" db:"job_count"`
+	LastJobID   uuid.UUID `json:"last_job_id" db:"last_job_id"`
+	FirstSeenAt time.Time `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
 type SearchHistoryEntry struct {
 	ID          uuid.UUID  `json:"id" db:"id"`
 	TenantID    uuid.UUID  `json:"tenant_id" db:"tenant_id"`
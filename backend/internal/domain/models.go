@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,8 +15,36 @@ const (
 	LogTypeSQL        LogType = "SQL"
 	LogTypeFilter     LogType = "FLTR"
 	LogTypeEscalation LogType = "ESCL"
+
+	// LogTypeHTTP and LogTypeGC are the entries of non-AR log sources: the
+	// requests of a Tomcat access log and the pauses of a JVM GC log.
+	LogTypeHTTP LogType = "HTTP"
+	LogTypeGC   LogType = "GC"
+)
+
+// LogSourceType is the kind of log an uploaded file holds. AR Server logs
+// go through the JAR; other sources are parsed by a log source plug-in.
+type LogSourceType string
+
+const (
+	LogSourceARServer     LogSourceType = "ar_server"
+	LogSourceTomcatAccess LogSourceType = "tomcat_access"
+	LogSourceJVMGC        LogSourceType = "jvm_gc"
 )
 
+// LogSourceTypes lists the known log source types.
+var LogSourceTypes = []LogSourceType{LogSourceARServer, LogSourceTomcatAccess, LogSourceJVMGC}
+
+// Valid reports whether s is a known log source type.
+func (s LogSourceType) Valid() bool {
+	for _, t := range LogSourceTypes {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
 // JobStatus represents the lifecycle state of an analysis job.
 type JobStatus string
 
@@ -26,8 +55,385 @@ const (
 	JobStatusStoring   JobStatus = "storing"
 	JobStatusComplete  JobStatus = "complete"
 	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled" // Stopped at the request of a user
+)
+
+// Terminal reports whether a job in the status is done with: completed,
+// failed or cancelled.
+func (s JobStatus) Terminal() bool {
+	return s == JobStatusComplete || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// JobPriority decides the order in which workers take queued jobs.
+type JobPriority string
+
+const (
+	JobPriorityInteractive JobPriority = "interactive" // Quick looks during an incident
+	JobPriorityNormal      JobPriority = "normal"
+	JobPriorityBatch       JobPriority = "batch" // Large captures that can wait
+)
+
+// JobPriorities lists the priorities from highest to lowest.
+var JobPriorities = []JobPriority{JobPriorityInteractive, JobPriorityNormal, JobPriorityBatch}
+
+// Files up to these sizes default to interactive and normal priority.
+const (
+	InteractiveMaxBytes = 100 << 20
+	NormalMaxBytes      = 1 << 30
 )
 
+// Valid reports whether p is a known priority.
+func (p JobPriority) Valid() bool {
+	return p.Rank() >= 0
+}
+
+// Rank is the position of p in JobPriorities, 0 being the highest, or -1
+// for an unknown priority.
+func (p JobPriority) Rank() int {
+	for i, q := range JobPriorities {
+		if p == q {
+			return i
+		}
+	}
+	return -1
+}
+
+// OrDefault returns p, or normal when p is empty as it is on jobs queued
+// before priorities existed.
+func (p JobPriority) OrDefault() JobPriority {
+	if p == "" {
+		return JobPriorityNormal
+	}
+	return p
+}
+
+// DefaultJobPriority is the priority of a job analysing a file of the
+// given size.
+func DefaultJobPriority(sizeBytes int64) JobPriority {
+	switch {
+	case sizeBytes <= InteractiveMaxBytes:
+		return JobPriorityInteractive
+	case sizeBytes <= NormalMaxBytes:
+		return JobPriorityNormal
+	default:
+		return JobPriorityBatch
+	}
+}
+
+// JobQueueEstimate is where a queued job stands and when it is expected to
+// start. Position is 1 for the next job to start.
+type JobQueueEstimate struct {
+	Position         int       `json:"position"`
+	JobsAhead        int       `json:"jobs_ahead"`
+	EstimatedStartAt time.Time `json:"estimated_start_at"`
+	EstimatedWaitMS  int64     `json:"estimated_wait_ms"`
+}
+
+// QueuedJob is a queued or running job as seen by the queue estimator.
+type QueuedJob struct {
+	ID        uuid.UUID
+	TenantID  uuid.UUID
+	Priority  JobPriority
+	SizeBytes int64
+	JVMHeapMB int
+	CreatedAt time.Time
+	StartedAt *time.Time // Set for running jobs
+}
+
+// JobQueueSnapshot is the state of the job queue across all tenants.
+// MSPerMB is the processing rate of recently completed jobs, or 0 when
+// there are none.
+type JobQueueSnapshot struct {
+	Queued  []QueuedJob
+	Running []QueuedJob
+	MSPerMB float64
+}
+
+// Sources of the cost model an estimate was computed with.
+const (
+	CostModelDefault = "default" // Too few jobs of the tenant to fit on
+	CostModelFitted  = "fitted"  // Fitted on the tenant's completed jobs
+)
+
+// LinearFit is a straight line y = Intercept + Slope·x.
+type LinearFit struct {
+	Intercept float64 `json:"intercept"`
+	Slope     float64 `json:"slope"`
+}
+
+// At returns the value of the line at x, never below zero.
+func (f LinearFit) At(x float64) float64 {
+	return max(f.Intercept+f.Slope*x, 0)
+}
+
+// JobCostModel predicts what analysing a file of a tenant costs, from the
+// tenant's completed jobs of the file's source type: the rows stored from
+// the file's bytes, the JAR's run time from the bytes and the insert time
+// from the rows. ErrorPct is the mean absolute error of the processing
+// time estimated for the jobs it was fitted on, as a percentage.
+type JobCostModel struct {
+	TenantID           uuid.UUID     `json:"tenant_id" db:"tenant_id"`
+	SourceType         LogSourceType `json:"source_type" db:"source_type"`
+	Jobs               int           `json:"jobs" db:"jobs"`
+	Rows               LinearFit     `json:"rows" db:"rows_fit"`
+	JARMS              LinearFit     `json:"jar_ms" db:"jar_ms_fit"`
+	InsertMS           LinearFit     `json:"insert_ms" db:"insert_ms_fit"`
+	StorageBytesPerRow float64       `json:"storage_bytes_per_row" db:"storage_bytes_per_row"`
+	ErrorPct           *float64      `json:"error_pct,omitempty" db:"error_pct"`
+	FittedAt           time.Time     `json:"fitted_at" db:"fitted_at"`
+}
+
+// JobCostSample is a completed job that cost models are fitted on.
+// InsertMS is the time the job took beyond the JAR's run; QueueMS the time
+// it waited to start. Sampled jobs stored a fraction of their rows and are
+// not fitted on.
+type JobCostSample struct {
+	JobID      uuid.UUID
+	TenantID   uuid.UUID
+	SourceType LogSourceType
+	SizeBytes  int64
+	Rows       int64
+	JARMS      int64
+	InsertMS   int64
+	QueueMS    int64
+	Sampled    bool
+
+	// Estimate is the estimate stored when the job was submitted, and
+	// Accuracy its comparison with the job's actual cost once recorded.
+	Estimate *JobEstimate
+	Accuracy *EstimateAccuracy
+}
+
+// JobEstimate is what analysing a file is expected to cost before it is
+// submitted: how long it takes to process and waits in the queue, and what
+// it stores. SamplingRecommended is set when the file would store enough
+// rows that sampling it is advised, at SuggestedSampleRate; QuotaExceeded
+// names the tenant quotas the analysis would exceed.
+type JobEstimate struct {
+	SizeBytes           int64         `json:"size_bytes"`
+	SourceType          LogSourceType `json:"source_type"`
+	SampleRate          int           `json:"sample_rate,omitempty"`
+	ProcessingMS        int64         `json:"processing_ms"`
+	JARMS               int64         `json:"jar_ms"`
+	InsertMS            int64         `json:"insert_ms"`
+	QueueWaitMS         int64         `json:"queue_wait_ms"`
+	Rows                int64         `json:"rows"`
+	StorageBytes        int64         `json:"storage_bytes"`
+	SamplingRecommended bool          `json:"sampling_recommended"`
+	SuggestedSampleRate int           `json:"suggested_sample_rate,omitempty"`
+	QuotaExceeded       []string      `json:"quota_exceeded,omitempty"`
+	Model               string        `json:"model"`
+	ModelJobs           int           `json:"model_jobs"`
+	EstimatedAt         time.Time     `json:"estimated_at"`
+}
+
+// EstimateAccuracy compares a job's estimate with what the job actually
+// cost. Error percentages are relative to the actual values.
+type EstimateAccuracy struct {
+	ProcessingMS       int64     `json:"processing_ms"`
+	QueueWaitMS        int64     `json:"queue_wait_ms"`
+	Rows               int64     `json:"rows"`
+	ProcessingErrorPct float64   `json:"processing_error_pct"`
+	QueueWaitErrorPct  float64   `json:"queue_wait_error_pct"`
+	RowsErrorPct       float64   `json:"rows_error_pct"`
+	RecordedAt         time.Time `json:"recorded_at"`
+}
+
+// ActiveJob is a queued or running job with the latest event of its job
+// event log, if any.
+type ActiveJob struct {
+	ID          uuid.UUID
+	TenantID    uuid.UUID
+	Status      JobStatus
+	Priority    JobPriority
+	ProgressPct int
+	CreatedAt   time.Time
+	StartedAt   *time.Time
+	LastEvent   *JobEvent
+}
+
+// WorkerHeartbeat is the last sign of life of a worker process. Workers
+// record one every few seconds, busy or idle.
+type WorkerHeartbeat struct {
+	WorkerID    string    `json:"worker_id" db:"worker_id"`
+	Hostname    string    `json:"hostname" db:"hostname"`
+	StartedAt   time.Time `json:"started_at" db:"started_at"`
+	RunningJobs int       `json:"running_jobs" db:"running_jobs"`
+	MaxJobs     int       `json:"max_jobs" db:"max_jobs"`
+	LastSeenAt  time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+// Background task run triggers and outcomes.
+const (
+	TaskTriggerSchedule = "schedule"
+	TaskTriggerManual   = "manual"
+
+	TaskOutcomeSuccess = "success"
+	TaskOutcomeError   = "error"
+	TaskOutcomeTimeout = "timeout"
+	TaskOutcomePanic   = "panic"
+)
+
+// BackgroundTask is a periodic task of the workers, such as the trash purge
+// or the retention reconciliation. Every worker registers the tasks it
+// runs; one of them runs each due task at a time, holding its lock.
+type BackgroundTask struct {
+	Name           string    `json:"name" db:"name"`
+	Schedule       string    `json:"schedule" db:"schedule"`
+	TimeoutSeconds int       `json:"timeout_seconds" db:"timeout_seconds"`
+	NextRunAt      time.Time `json:"next_run_at" db:"next_run_at"`
+	RegisteredAt   time.Time `json:"registered_at" db:"registered_at"`
+
+	// RunRequestedAt is set when an operator asked for a run outside the
+	// schedule that has not started yet.
+	RunRequestedAt *time.Time `json:"run_requested_at,omitempty" db:"run_requested_at"`
+	RunRequestedBy string     `json:"run_requested_by,omitempty" db:"run_requested_by"`
+
+	// LockedBy is the worker running the task until LockedUntil at the
+	// latest. Running is set while the lock is held.
+	LockedBy    string     `json:"locked_by,omitempty" db:"locked_by"`
+	LockedUntil *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	Running     bool       `json:"running" db:"-"`
+
+	// LastRun is the latest finished run. It is filled in by the task list.
+	LastRun *BackgroundTaskRun `json:"last_run,omitempty" db:"-"`
+}
+
+// BackgroundTaskRun is one finished run of a background task.
+type BackgroundTaskRun struct {
+	ID         int64     `json:"id" db:"id"`
+	TaskName   string    `json:"task_name" db:"task_name"`
+	Trigger    string    `json:"trigger" db:"trigger"`
+	WorkerID   string    `json:"worker_id" db:"worker_id"`
+	StartedAt  time.Time `json:"started_at" db:"started_at"`
+	FinishedAt time.Time `json:"finished_at" db:"finished_at"`
+	DurationMS int64     `json:"duration_ms" db:"duration_ms"`
+	Outcome    string    `json:"outcome" db:"outcome"`
+	Error      string    `json:"error,omitempty" db:"error"`
+}
+
+// QueueActivity is the state of the job queue read for the operators'
+// queue status: the active jobs, the worker heartbeats, and the number of
+// jobs that finished since the start of the throughput window.
+type QueueActivity struct {
+	Jobs          []ActiveJob
+	Workers       []WorkerHeartbeat
+	FinishedSince time.Time
+	FinishedCount int64
+}
+
+// StreamDepth is the backlog of a durable JetStream consumer of job
+// submissions: messages not yet delivered and delivered ones not yet
+// acknowledged.
+type StreamDepth struct {
+	Consumer   string `json:"consumer"`
+	Subject    string `json:"subject"`
+	Pending    uint64 `json:"pending"`
+	AckPending int    `json:"ack_pending"`
+}
+
+// JobStatusCount is the number of jobs of a tenant in one status and the
+// age of the oldest, from creation for queued jobs and from start for the
+// others.
+type JobStatusCount struct {
+	Count       int   `json:"count"`
+	OldestAgeMS int64 `json:"oldest_age_ms"`
+}
+
+// TenantQueueStatus counts the active jobs of a tenant by status.
+type TenantQueueStatus struct {
+	TenantID uuid.UUID                    `json:"tenant_id"`
+	Statuses map[JobStatus]JobStatusCount `json:"statuses"`
+}
+
+// WaitingJob is a queued job and how long it has waited.
+type WaitingJob struct {
+	JobID     uuid.UUID   `json:"job_id"`
+	TenantID  uuid.UUID   `json:"tenant_id"`
+	Priority  JobPriority `json:"priority"`
+	CreatedAt time.Time   `json:"created_at"`
+	WaitMS    int64       `json:"wait_ms"`
+}
+
+// RunningJob is a running job with its stage, progress and last activity.
+type RunningJob struct {
+	JobID       uuid.UUID  `json:"job_id"`
+	TenantID    uuid.UUID  `json:"tenant_id"`
+	Status      JobStatus  `json:"status"`
+	Stage       string     `json:"stage,omitempty"`
+	ProgressPct int        `json:"progress_pct"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	RunningMS   int64      `json:"running_ms"`
+	LastEventAt *time.Time `json:"last_event_at,omitempty"`
+	LastMessage string     `json:"last_message,omitempty"`
+}
+
+// WorkerStatus is a worker heartbeat and whether the worker is considered
+// alive.
+type WorkerStatus struct {
+	WorkerHeartbeat
+	Alive bool `json:"alive"`
+}
+
+// QueueStatus is the operators' view of the job queue.
+type QueueStatus struct {
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Tenants      []TenantQueueStatus `json:"tenants"`
+	OldestQueued *WaitingJob         `json:"oldest_queued,omitempty"`
+	Running      []RunningJob        `json:"running"`
+	Workers      []WorkerStatus      `json:"workers"`
+	LiveWorkers  int                 `json:"live_workers"`
+	Stream       []StreamDepth       `json:"stream"`
+	StreamError  string              `json:"stream_error,omitempty"`
+
+	// FinishedLastHour is the number of jobs that completed or failed in
+	// the last hour. The backlog ETA divides the queued jobs by that rate;
+	// it is omitted when nothing finished or no worker is alive.
+	FinishedLastHour int64  `json:"finished_last_hour"`
+	QueuedJobs       int    `json:"queued_jobs"`
+	BacklogETAMS     *int64 `json:"backlog_eta_ms,omitempty"`
+}
+
+// LogFormat identifies the AR Server log layout detected in an uploaded file.
+type LogFormat string
+
+const (
+	LogFormatAR25    LogFormat = "ar-25.x" // Layout expected by the bundled JAR (TrID present)
+	LogFormatAR20    LogFormat = "ar-20.x" // TrID present, but the file banner reports a pre-25 server
+	LogFormatAR9     LogFormat = "ar-9.x"  // Pre-19 layout without Trace IDs
+	LogFormatUnknown LogFormat = "unknown"
+)
+
+// RetentionClass decides how long a tenant's log entries stay in ClickHouse
+// and when they move to cold storage.
+type RetentionClass string
+
+const (
+	RetentionTrial      RetentionClass = "trial"
+	RetentionStandard   RetentionClass = "standard"
+	RetentionEnterprise RetentionClass = "enterprise"
+)
+
+// SQLRedaction decides what becomes of the literal values of the SQL
+// statements a tenant's captures log, such as the names and emails compared
+// in WHERE clauses, before they are stored.
+type SQLRedaction string
+
+const (
+	SQLRedactionOff    SQLRedaction = "off"    // Stored as logged
+	SQLRedactionHash   SQLRedaction = "hash"   // Replaced by a token, equal for equal values
+	SQLRedactionRemove SQLRedaction = "remove" // Replaced by a placeholder
+)
+
+// Valid reports whether r is a known policy.
+func (r SQLRedaction) Valid() bool {
+	switch r {
+	case SQLRedactionOff, SQLRedactionHash, SQLRedactionRemove:
+		return true
+	}
+	return false
+}
+
 // Tenant represents an organization using the platform.
 type Tenant struct {
 	ID             uuid.UUID `json:"id" db:"id"`
@@ -37,6 +443,162 @@ type Tenant struct {
 	StorageLimitGB int       `json:"storage_limit_gb" db:"storage_limit_gb"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+
+	// RetentionClass is the class last applied to the tenant's stored log
+	// entries. It trails Plan until the retention reconciler catches up.
+	RetentionClass RetentionClass `json:"retention_class" db:"retention_class"`
+
+	// Region is the data residency region the tenant's files and log
+	// entries are kept in. Empty means the default storage.
+	Region string `json:"region" db:"region"`
+
+	// Sandbox marks a trial or demo tenant held to the sandbox quotas. Its
+	// data is purged at SandboxExpiresAt, after which it is archived,
+	// unless it is converted to a full tenant first.
+	Sandbox          bool       `json:"sandbox" db:"sandbox"`
+	SandboxExpiresAt *time.Time `json:"sandbox_expires_at,omitempty" db:"sandbox_expires_at"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+
+	// SQLRedaction applies to the SQL statements of the analyses ingested
+	// after it is set; those already stored are left as they are.
+	SQLRedaction SQLRedaction `json:"sql_redaction" db:"sql_redaction"`
+}
+
+// Built-in quotas of sandbox tenants. They override the quotas configured
+// for a tenant, unless those are tighter.
+const (
+	SandboxMaxFileBytes = 100 << 20 // Largest file a sandbox may upload
+	SandboxMaxAnalyses  = 25        // Analyses a sandbox may hold, trashed ones included
+
+	// SandboxMaxLifetime bounds how long after its creation a sandbox may
+	// expire.
+	SandboxMaxLifetime = 30 * 24 * time.Hour
+)
+
+// TenantQuotas bound what a tenant may upload and analyse. Zero values are
+// unlimited.
+type TenantQuotas struct {
+	MaxFileBytes int64 `json:"max_file_bytes"`
+	MaxAnalyses  int   `json:"max_analyses"`
+}
+
+// Quotas returns the quotas of the tenant given those configured for it:
+// a sandbox gets the tighter of the configured and the sandbox quotas,
+// other tenants the configured ones. A nil tenant is not a sandbox.
+func (t *Tenant) Quotas(configured TenantQuotas) TenantQuotas {
+	if t == nil || !t.Sandbox {
+		return configured
+	}
+	return TenantQuotas{
+		MaxFileBytes: tighterQuota(configured.MaxFileBytes, SandboxMaxFileBytes),
+		MaxAnalyses:  int(tighterQuota(int64(configured.MaxAnalyses), SandboxMaxAnalyses)),
+	}
+}
+
+// SandboxExpired reports whether the tenant is a sandbox past its expiry.
+func (t *Tenant) SandboxExpired(now time.Time) bool {
+	return t != nil && t.Sandbox && t.SandboxExpiresAt != nil && !now.Before(*t.SandboxExpiresAt)
+}
+
+// tighterQuota returns the lower of two quotas, where zero is unlimited.
+func tighterQuota(configured, builtin int64) int64 {
+	if configured <= 0 {
+		return builtin
+	}
+	return min(configured, builtin)
+}
+
+// TenantStorage is a tenant's share of the log_entries table in ClickHouse,
+// aggregated from the active parts.
+type TenantStorage struct {
+	TenantID    string            `json:"tenant_id"`
+	Rows        uint64            `json:"rows"`
+	Bytes       uint64            `json:"bytes"`
+	BytesByDisk map[string]uint64 `json:"bytes_by_disk"`
+}
+
+// TenantRetention is a tenant's effective retention and current storage use.
+type TenantRetention struct {
+	TenantID       uuid.UUID         `json:"tenant_id"`
+	Name           string            `json:"name"`
+	Plan           string            `json:"plan"`
+	RetentionClass RetentionClass    `json:"retention_class"`
+	Pending        bool              `json:"pending"` // Stored rows still carry an older class
+	Sandbox        bool              `json:"sandbox"`
+	HotDays        int               `json:"hot_days"`
+	RetentionDays  int               `json:"retention_days"`
+	Rows           uint64            `json:"rows"`
+	Bytes          uint64            `json:"bytes"`
+	BytesByDisk    map[string]uint64 `json:"bytes_by_disk"`
+}
+
+// UsageMetric names a billable quantity accounted per tenant and month.
+type UsageMetric string
+
+const (
+	UsageBytesUploaded UsageMetric = "bytes_uploaded" // Size of uploaded log files
+	UsageAnalysesRun   UsageMetric = "analyses_run"   // Analysis jobs completed
+	UsageRowsStored    UsageMetric = "rows_stored"    // Log entries written to ClickHouse
+	UsageWorkerMS      UsageMetric = "worker_ms"      // JAR wall time consumed on workers
+	UsageAIQueries     UsageMetric = "ai_queries"     // AI assistant answers completed
+)
+
+// UsageEvent is one accounted quantity. SourceID identifies the operation it
+// was measured on (file, job or message), so recording the same event twice
+// counts it once.
+type UsageEvent struct {
+	TenantID   uuid.UUID   `json:"tenant_id" db:"tenant_id"`
+	Metric     UsageMetric `json:"metric" db:"metric"`
+	SourceID   uuid.UUID   `json:"source_id" db:"source_id"`
+	Amount     int64       `json:"amount" db:"amount"`
+	OccurredAt time.Time   `json:"occurred_at" db:"occurred_at"`
+}
+
+// UsageTotals holds the value of every usage metric over a period.
+type UsageTotals struct {
+	BytesUploaded int64 `json:"bytes_uploaded"`
+	AnalysesRun   int64 `json:"analyses_run"`
+	RowsStored    int64 `json:"rows_stored"`
+	WorkerMS      int64 `json:"worker_ms"`
+	AIQueries     int64 `json:"ai_queries"`
+}
+
+// UsageMonth is the usage of one calendar month (UTC).
+type UsageMonth struct {
+	Month string `json:"month"` // YYYY-MM
+	UsageTotals
+}
+
+// TenantUsage is the monthly usage series of a tenant, or of every tenant
+// when TenantID is nil. Months without usage are included with zero values.
+type TenantUsage struct {
+	TenantID    *uuid.UUID   `json:"tenant_id,omitempty"`
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	Months      []UsageMonth `json:"months"`
+	MonthToDate UsageMonth   `json:"month_to_date"`
+
+	// Cache is the Redis cache usage of the tenant. Caches lists that of
+	// every tenant with cached data, largest first, in the usage of every
+	// tenant.
+	Cache  *TenantCacheUsage  `json:"cache,omitempty"`
+	Caches []TenantCacheUsage `json:"caches,omitempty"`
+
+	// Uploads is the state of the upload limits of the tenant, or of the
+	// API replica in the usage of every tenant.
+	Uploads *UploadLimits `json:"uploads,omitempty"`
+}
+
+// TenantCacheUsage is the approximate Redis memory taken by the caches of a
+// tenant: the bytes written within the longest cache TTL, by namespace such
+// as "dashboard" or "trace". Writes past the tenant's budget are skipped
+// rather than stored.
+type TenantCacheUsage struct {
+	TenantID      string           `json:"tenant_id"`
+	Bytes         int64            `json:"bytes"`
+	BudgetBytes   int64            `json:"budget_bytes,omitempty"`
+	Namespaces    map[string]int64 `json:"namespaces"`
+	SkippedWrites int64            `json:"skipped_writes"`
 }
 
 // LogFile represents an uploaded log file.
@@ -51,34 +613,422 @@ type LogFile struct {
 	DetectedTypes  []string  `json:"detected_types" db:"detected_types"`
 	ChecksumSHA256 string    `json:"checksum_sha256,omitempty" db:"checksum_sha256"`
 	UploadedAt     time.Time `json:"uploaded_at" db:"uploaded_at"`
+
+	// SourceType is the kind of log the file holds, ar_server unless the
+	// upload said otherwise.
+	SourceType LogSourceType `json:"source_type" db:"source_type"`
 }
 
 // AnalysisJob represents a log analysis run.
 type AnalysisJob struct {
-	ID             uuid.UUID  `json:"id" db:"id"`
-	TenantID       uuid.UUID  `json:"tenant_id" db:"tenant_id"`
-	Status         JobStatus  `json:"status" db:"status"`
-	FileID         uuid.UUID  `json:"file_id" db:"file_id"`
-	JARFlags       JARFlags   `json:"jar_flags" db:"jar_flags"`
-	JVMHeapMB      int        `json:"jvm_heap_mb" db:"jvm_heap_mb"`
-	TimeoutSeconds int        `json:"timeout_seconds" db:"timeout_seconds"`
-	ProgressPct    int        `json:"progress_pct" db:"progress_pct"`
-	TotalLines     *int64     `json:"total_lines,omitempty" db:"total_lines"`
-	ProcessedLines *int64     `json:"processed_lines,omitempty" db:"processed_lines"`
-	APICount       *int64     `json:"api_count,omitempty" db:"api_count"`
-	SQLCount       *int64     `json:"sql_count,omitempty" db:"sql_count"`
-	FilterCount    *int64     `json:"filter_count,omitempty" db:"filter_count"`
-	EscCount       *int64     `json:"esc_count,omitempty" db:"esc_count"`
-	StartTime      *time.Time `json:"start_time,omitempty" db:"start_time"`
-	EndTime        *time.Time `json:"end_time,omitempty" db:"end_time"`
-	LogStart       *time.Time `json:"log_start,omitempty" db:"log_start"`
-	LogEnd         *time.Time `json:"log_end,omitempty" db:"log_end"`
-	LogDuration    *string    `json:"log_duration,omitempty" db:"log_duration"`
-	ErrorMessage   *string    `json:"error_message,omitempty" db:"error_message"`
-	JARStderr      *string    `json:"jar_stderr,omitempty" db:"jar_stderr"`
-	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
-	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	ID             uuid.UUID   `json:"id" db:"id"`
+	TenantID       uuid.UUID   `json:"tenant_id" db:"tenant_id"`
+	Status         JobStatus   `json:"status" db:"status"`
+	Priority       JobPriority `json:"priority" db:"priority"`
+	FileID         uuid.UUID   `json:"file_id" db:"file_id"`
+	JARFlags       JARFlags    `json:"jar_flags" db:"jar_flags"`
+	JVMHeapMB      int         `json:"jvm_heap_mb" db:"jvm_heap_mb"`
+	TimeoutSeconds int         `json:"timeout_seconds" db:"timeout_seconds"`
+	ProgressPct    int         `json:"progress_pct" db:"progress_pct"`
+	TotalLines     *int64      `json:"total_lines,omitempty" db:"total_lines"`
+	ProcessedLines *int64      `json:"processed_lines,omitempty" db:"processed_lines"`
+	APICount       *int64      `json:"api_count,omitempty" db:"api_count"`
+	SQLCount       *int64      `json:"sql_count,omitempty" db:"sql_count"`
+	FilterCount    *int64      `json:"filter_count,omitempty" db:"filter_count"`
+	EscCount       *int64      `json:"esc_count,omitempty" db:"esc_count"`
+	StartTime      *time.Time  `json:"start_time,omitempty" db:"start_time"`
+	EndTime        *time.Time  `json:"end_time,omitempty" db:"end_time"`
+	LogStart       *time.Time  `json:"log_start,omitempty" db:"log_start"`
+	LogEnd         *time.Time  `json:"log_end,omitempty" db:"log_end"`
+	LogDuration    *string     `json:"log_duration,omitempty" db:"log_duration"`
+	ErrorMessage   *string     `json:"error_message,omitempty" db:"error_message"`
+	JARStderr      *string     `json:"jar_stderr,omitempty" db:"jar_stderr"`
+	PeakRSSKB      *int64      `json:"peak_rss_kb,omitempty" db:"peak_rss_kb"`
+	CPUTimeMS      *int64      `json:"cpu_time_ms,omitempty" db:"cpu_time_ms"`
+	WallTimeMS     *int64      `json:"wall_time_ms,omitempty" db:"wall_time_ms"`
+	LogFormat      *LogFormat  `json:"log_format,omitempty" db:"log_format"`
+	ViolationCount *int        `json:"violation_count,omitempty" db:"violation_count"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+	CompletedAt    *time.Time  `json:"completed_at,omitempty" db:"completed_at"`
+	StartedAt      *time.Time  `json:"started_at,omitempty" db:"started_at"`
+
+	// CancelRequestedAt is set when a user asked for the job to be
+	// cancelled. A running job is stopped by its worker; from then on it
+	// can only end cancelled.
+	CancelRequestedAt *time.Time `json:"cancel_requested_at,omitempty" db:"cancel_requested_at"`
+
+	// Queue is where the job stands while it is queued. It is computed when
+	// the job is read, not stored.
+	Queue *JobQueueEstimate `json:"queue,omitempty" db:"-"`
+
+	// CorrectClockSkew shifts the timestamps of captured files whose clock
+	// differs from the reference file by the estimated offset at insert time.
+	CorrectClockSkew bool             `json:"correct_clock_skew" db:"correct_clock_skew"`
+	ClockSkew        *ClockSkewReport `json:"clock_skew,omitempty" db:"clock_skew"`
+
+	// Restarts lists the AR Server restarts detected within the capture.
+	Restarts []RestartEvent `json:"restarts,omitempty" db:"restarts"`
+
+	// ThreadCounts is the number of threads configured per queue, as the
+	// JAR preamble reported it.
+	ThreadCounts map[string]int `json:"thread_counts,omitempty" db:"thread_counts"`
+
+	// IngestionFilters counts the entries each of the tenant's ingestion
+	// filter rules dropped or flagged as noise.
+	IngestionFilters []IngestionFilterMatch `json:"ingestion_filters,omitempty" db:"ingestion_filter_stats"`
+
+	// Links are the external records the analysis is linked to. They are
+	// filled in by the analyses list, not stored with the job.
+	Links []AnalysisLink `json:"links,omitempty" db:"-"`
+
+	// Sections records which log types the capture holds, so that clients
+	// can hide the tabs of absent ones.
+	Sections *SectionPresence `json:"sections,omitempty" db:"section_presence"`
+
+	// DataQuality classifies how far the entries stored for the job diverge
+	// from the counts the JAR reported; Reconciliation holds the counts.
+	DataQuality    *DataQuality             `json:"data_quality,omitempty" db:"data_quality"`
+	Reconciliation *IngestionReconciliation `json:"reconciliation,omitempty" db:"ingestion_reconciliation"`
+
+	// FirstErrorAt is the timestamp of the earliest failed entry, copied
+	// from the error onset so that it can be read with the job.
+	FirstErrorAt *time.Time `json:"first_error_at,omitempty" db:"first_error_at"`
+
+	// FocusWindow is the stretch of the capture the dashboard opens on. It
+	// is nil when no stretch stands out from the rest.
+	FocusWindow *FocusWindow `json:"focus_window,omitempty" db:"focus_window"`
+
+	// CaptureProfile is how the capture was taken, and CaptureDrift how
+	// that differs from the tenant's reference capture. CaptureBaseline
+	// marks the analysis later captures of the tenant are checked against.
+	CaptureProfile  *CaptureProfile `json:"capture_profile,omitempty" db:"capture_profile"`
+	CaptureDrift    *CaptureDrift   `json:"capture_drift,omitempty" db:"capture_drift"`
+	CaptureBaseline bool            `json:"capture_baseline,omitempty" db:"capture_baseline"`
+
+	// Integrity is the pre-flight check of the uploaded file. ErrorCode is
+	// set when a fatal integrity issue failed the job.
+	Integrity *FileIntegrity `json:"integrity,omitempty" db:"file_integrity"`
+	ErrorCode *string        `json:"error_code,omitempty" db:"error_code"`
+
+	// EventsURL points at the job's event timeline. It is set on the
+	// job_complete message, not stored.
+	EventsURL string `json:"events_url,omitempty" db:"-"`
+
+	// FullReprocess is set on a job submitted to be processed from
+	// scratch: the worker discards the checkpoint of an earlier run instead
+	// of resuming it. It travels with the submission, not stored.
+	FullReprocess bool `json:"full_reprocess,omitempty" db:"-"`
+
+	// DeletedAt is set while the analysis is in the trash. Trashed analyses
+	// are hidden from every read path until restored or purged.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// Sampling is set when the analysis ingests a sample of its capture
+	// rather than every entry.
+	Sampling *Sampling `json:"sampling,omitempty" db:"sampling"`
+
+	// IncidentGroupID links the analysis to the other analyses of the same
+	// incident, such as those of the server's access and GC logs.
+	IncidentGroupID *uuid.UUID `json:"incident_group_id,omitempty" db:"incident_group_id"`
+
+	// Sandbox is set on the analyses of sandbox tenants.
+	Sandbox bool `json:"sandbox,omitempty" db:"sandbox"`
+
+	// Estimate is the cost estimated when the job was submitted, and
+	// EstimateAccuracy how it compared with the job's actual cost once the
+	// job completed.
+	Estimate         *JobEstimate      `json:"estimate,omitempty" db:"estimate"`
+	EstimateAccuracy *EstimateAccuracy `json:"estimate_accuracy,omitempty" db:"estimate_accuracy"`
+
+	Investigation Investigation `json:"investigation"`
+}
+
+// Sample rate bounds and the default duration above which entries of a
+// sampled capture are always ingested.
+const (
+	MinSampleRate                  = 2
+	MaxSampleRate                  = 10000
+	DefaultSamplingSlowThresholdMS = 1000
+)
+
+// Sampling configures and reports the sampled ingestion of a capture too
+// large to ingest in full. One trace in Rate is ingested, chosen by a hash
+// of its trace ID so that whole transactions are kept, and each of its
+// entries stands for Rate entries. Failed entries, entries of at least
+// SlowThresholdMS and entries within the hot windows are always ingested
+// and stand for themselves.
+type Sampling struct {
+	Rate            int    `json:"rate"`
+	SlowThresholdMS uint32 `json:"slow_threshold_ms"`
+
+	// HotWindows are the periods around anomalies and error rate crossings
+	// ingested at full fidelity by a second pass over the capture.
+	HotWindows []HotWindow `json:"hot_windows,omitempty"`
+
+	// SampledEntries were ingested as part of the sample,
+	// FullFidelityEntries because they failed, were slow or fell within a
+	// hot window, and SkippedEntries were left out.
+	SampledEntries      int64 `json:"sampled_entries"`
+	FullFidelityEntries int64 `json:"full_fidelity_entries"`
+	SkippedEntries      int64 `json:"skipped_entries"`
+}
+
+// HotWindow is a period of a sampled capture ingested at full fidelity.
+type HotWindow struct {
+	Start  Timestamp `json:"start"`
+	End    Timestamp `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+// Contains reports whether t falls within the window, bounds included.
+func (w HotWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start.Time) && !t.After(w.End.Time)
+}
+
+// Estimate labels analytics computed from the entries of a sampled
+// capture: their counts are scaled up by the sample rate and are estimates
+// rather than exact figures.
+type Estimate struct {
+	Estimated  bool `json:"estimated,omitempty"`
+	SampleRate int  `json:"sample_rate,omitempty"`
+}
+
+// NewEstimate returns the estimate label of analytics whose entries stand
+// for at most maxWeight entries each. Unsampled analytics are exact.
+func NewEstimate(maxWeight uint32) Estimate {
+	if maxWeight <= 1 {
+		return Estimate{}
+	}
+	return Estimate{Estimated: true, SampleRate: int(maxWeight)}
+}
+
+// File integrity issue codes. The code of the first fatal issue becomes the
+// error code of the failed job.
+const (
+	IntegrityEmptyFile       = "empty_file"
+	IntegrityBinaryContent   = "binary_content"
+	IntegrityInvalidEncoding = "invalid_encoding"
+	IntegrityNoLineBreaks    = "no_line_breaks"
+	IntegrityLineLength      = "implausible_line_length"
+	IntegrityNotARLog        = "not_ar_log"
+	IntegrityUnexpectedTail  = "unexpected_tail"
+	IntegrityTruncated       = "truncated"
+)
+
+// IntegritySeverity tells whether an integrity issue fails the job.
+type IntegritySeverity string
+
+const (
+	IntegrityWarning IntegritySeverity = "warning"
+	IntegrityFatal   IntegritySeverity = "fatal"
+)
+
+// IntegrityIssue is one problem found while checking an uploaded log file.
+// Offset is the byte offset of the first affected byte and Count the
+// number of affected bytes in the sampled data, when they apply.
+type IntegrityIssue struct {
+	Code     string            `json:"code"`
+	Severity IntegritySeverity `json:"severity"`
+	Message  string            `json:"message"`
+	Offset   *int64            `json:"offset,omitempty"`
+	Count    int64             `json:"count,omitempty"`
+}
+
+// FileIntegrity is the result of the pre-flight check of an uploaded log
+// file. Only a sample of large files is read.
+type FileIntegrity struct {
+	SizeBytes      int64            `json:"size_bytes"`
+	SampledBytes   int64            `json:"sampled_bytes"`
+	EstimatedLines int64            `json:"estimated_lines"`
+	Issues         []IntegrityIssue `json:"issues"`
+}
+
+// Fatal returns the first fatal issue, or nil when the file can be analysed.
+func (f *FileIntegrity) Fatal() *IntegrityIssue {
+	for i := range f.Issues {
+		if f.Issues[i].Severity == IntegrityFatal {
+			return &f.Issues[i]
+		}
+	}
+	return nil
+}
+
+// PurgedJob lists what remains in object storage of an analysis deleted
+// from Postgres: its uploaded file, unless another analysis still uses it,
+// and the objects of its exports.
+type PurgedJob struct {
+	FileS3Key    string
+	ExportS3Keys []string
+}
+
+// InvestigationStatus is the incident lifecycle state of an analysis.
+type InvestigationStatus string
+
+const (
+	InvestigationNew                 InvestigationStatus = "new"
+	InvestigationInvestigating       InvestigationStatus = "investigating"
+	InvestigationRootCauseIdentified InvestigationStatus = "root_cause_identified"
+	InvestigationResolved            InvestigationStatus = "resolved"
+	InvestigationArchived            InvestigationStatus = "archived"
+)
+
+// Investigation is the workflow state analysts keep on an analysis.
+// Version increases with every update and is the optimistic concurrency
+// precondition for the next one.
+type Investigation struct {
+	Status    InvestigationStatus `json:"status" db:"investigation_status"`
+	Notes     string              `json:"notes" db:"investigation_notes"` // Markdown
+	Assignee  *string             `json:"assignee,omitempty" db:"investigation_assignee"`
+	Version   int                 `json:"version" db:"investigation_version"`
+	UpdatedAt *time.Time          `json:"updated_at,omitempty" db:"investigation_updated_at"`
+}
+
+// InvestigationUpdate is a change to an investigation. Nil fields are left
+// unchanged; an empty Assignee clears the assignment.
+type InvestigationUpdate struct {
+	Status   *InvestigationStatus
+	Notes    *string
+	Assignee *string
+}
+
+// InvestigationEvent records one investigation update in the history of an
+// analysis.
+type InvestigationEvent struct {
+	ID           uuid.UUID           `json:"id" db:"id"`
+	TenantID     uuid.UUID           `json:"tenant_id" db:"tenant_id"`
+	JobID        uuid.UUID           `json:"job_id" db:"job_id"`
+	ActorID      string              `json:"actor_id" db:"actor_id"`
+	FromStatus   InvestigationStatus `json:"from_status" db:"from_status"`
+	ToStatus     InvestigationStatus `json:"to_status" db:"to_status"`
+	Assignee     *string             `json:"assignee,omitempty" db:"assignee"`
+	NotesChanged bool                `json:"notes_changed" db:"notes_changed"`
+	Version      int                 `json:"version" db:"version"`
+	CreatedAt    time.Time           `json:"created_at" db:"created_at"`
+}
+
+// JobEventKind classifies a job event.
+type JobEventKind string
+
+const (
+	JobEventCreated       JobEventKind = "created"
+	JobEventStageStarted  JobEventKind = "stage_started"
+	JobEventStageFinished JobEventKind = "stage_finished"
+	JobEventProgress      JobEventKind = "progress"
+	JobEventRetry         JobEventKind = "retry"
+	JobEventPublish       JobEventKind = "publish"
+	JobEventWarning       JobEventKind = "warning"
+	JobEventCompleted     JobEventKind = "completed"
+	JobEventFailed        JobEventKind = "failed"
+	JobEventCancelled     JobEventKind = "cancelled"
+	JobEventDropped       JobEventKind = "events_dropped" // Events over the per-job cap were not recorded
+	JobEventDiagnostics   JobEventKind = "diagnostics"    // An admin downloaded the diagnostic bundle
+)
+
+// Terminal reports whether the kind ends the processing of a job.
+func (k JobEventKind) Terminal() bool {
+	return k == JobEventCompleted || k == JobEventFailed || k == JobEventCancelled
+}
+
+// Job event sources.
+const (
+	JobEventSourceAPI    = "api"
+	JobEventSourceWorker = "worker"
+)
+
+// JobEvent is one entry of the event log of an analysis: a pipeline stage
+// starting or finishing, a progress milestone, a retry, a NATS publish or a
+// warning.
+type JobEvent struct {
+	ID         int64          `json:"id" db:"id"`
+	TenantID   uuid.UUID      `json:"tenant_id" db:"tenant_id"`
+	JobID      uuid.UUID      `json:"job_id" db:"job_id"`
+	Source     string         `json:"source" db:"source"`
+	Kind       JobEventKind   `json:"kind" db:"kind"`
+	Stage      string         `json:"stage,omitempty" db:"stage"`
+	Message    string         `json:"message,omitempty" db:"message"`
+	Metadata   map[string]any `json:"metadata,omitempty" db:"metadata"`
+	OccurredAt time.Time      `json:"occurred_at" db:"occurred_at"`
+}
+
+// JobTimelineEvent is a job event with the time elapsed since the previous
+// event and since the first one.
+type JobTimelineEvent struct {
+	JobEvent
+	SincePreviousMS int64 `json:"since_previous_ms"`
+	SinceStartMS    int64 `json:"since_start_ms"`
+}
+
+// Stages a job checkpoint records as completed.
+const (
+	CheckpointStageJAR    = "jar"    // The JAR report is kept in object storage
+	CheckpointStageParse  = "parse"  // The report is parsed and its sections cached
+	CheckpointStageIngest = "ingest" // Every batch of entries is inserted
+)
+
+// JobCheckpoint is the progress of the ingestion of a job, saved as it goes
+// so that a run interrupted by a worker crash can be resumed. Batches are
+// numbered across both passes over the capture; those below NextBatch are
+// stored, and each was inserted with a deduplication token derived from
+// RunID and its index.
+type JobCheckpoint struct {
+	JobID          uuid.UUID `json:"job_id" db:"job_id"`
+	TenantID       uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	RunID          uuid.UUID `json:"run_id" db:"run_id"`
+	Stage          string    `json:"stage" db:"stage"`
+	JAROutputKey   string    `json:"jar_output_key,omitempty" db:"jar_output_key"`
+	OutputFormat   string    `json:"output_format,omitempty" db:"output_format"`
+	NextBatch      int       `json:"next_batch" db:"next_batch"`
+	LastBatchToken string    `json:"last_batch_token,omitempty" db:"last_batch_token"`
+	CacheSections  []string  `json:"cache_sections,omitempty" db:"cache_sections"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SupportGrant is a tenant's consent for platform support engineers to act
+// in the tenant until ExpiresAt. Read-only grants allow reads only.
+type SupportGrant struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	TenantID  uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	GrantedBy string     `json:"granted_by" db:"granted_by"`
+	Reason    string     `json:"reason,omitempty" db:"reason"`
+	ReadOnly  bool       `json:"read_only" db:"read_only"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedBy *string    `json:"revoked_by,omitempty" db:"revoked_by"`
+}
+
+// Active reports whether the grant can be used at now.
+func (g SupportGrant) Active(now time.Time) bool {
+	return g.RevokedAt == nil && now.Before(g.ExpiresAt)
+}
+
+// SupportAccessEvent records a request a support engineer made in a tenant
+// under a support grant. ActorUserID and ActorTenantID are who made it;
+// TenantID is the tenant it acted in.
+type SupportAccessEvent struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	TenantID      uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	GrantID       uuid.UUID `json:"grant_id" db:"grant_id"`
+	ActorUserID   string    `json:"actor_user_id" db:"actor_user_id"`
+	ActorTenantID string    `json:"actor_tenant_id" db:"actor_tenant_id"`
+	Method        string    `json:"method" db:"method"`
+	Path          string    `json:"path" db:"path"`
+	Status        int       `json:"status" db:"status"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// JobListFilter narrows ListJobsFiltered. Zero fields do not filter.
+type JobListFilter struct {
+	InvestigationStatus InvestigationStatus
+	Assignee            string
+}
+
+// JobResourceUsage captures the resources consumed by the JAR process of a job.
+type JobResourceUsage struct {
+	PeakRSSKB  int64 `json:"peak_rss_kb"`
+	CPUTimeMS  int64 `json:"cpu_time_ms"`
+	WallTimeMS int64 `json:"wall_time_ms"`
 }
 
 // JARFlags holds the configuration flags for ARLogAnalyzer.jar.
@@ -97,8 +1047,31 @@ type JARFlags struct {
 	SkipEsc      bool     `json:"skip_esc,omitempty"`
 	SkipFltr     bool     `json:"skip_fltr,omitempty"`
 	IncludeFTS   bool     `json:"include_fts,omitempty"`
+
+	// OutputFormat asks the JAR for a structured report, JAROutputXML or
+	// JAROutputJSON, instead of the text report. JARs that do not support
+	// it fall back to the text report.
+	OutputFormat string `json:"output_format,omitempty"`
+
+	// StrictParse is not passed to the JAR: it parses the report in strict
+	// mode and stores the parse diagnostics with the job.
+	StrictParse bool `json:"strict_parse,omitempty"`
+
+	// RawTextLimit is not passed to the JAR either: it caps the raw text
+	// stored per entry at that many characters, overriding the worker's
+	// default. Failed entries and entries of the report's top-N tables
+	// keep their full text. A negative limit keeps every entry in full.
+	RawTextLimit int `json:"raw_text_limit,omitempty"`
 }
 
+// Report formats of ARLogAnalyzer.jar. The text report is the default and
+// the only format older JARs write.
+const (
+	JAROutputText = "text"
+	JAROutputXML  = "xml"
+	JAROutputJSON = "json"
+)
+
 // LogEntry represents a single parsed log entry stored in ClickHouse.
 type LogEntry struct {
 	TenantID   string    `json:"tenant_id" ch:"tenant_id"`
@@ -106,8 +1079,8 @@ type LogEntry struct {
 	EntryID    string    `json:"entry_id" ch:"entry_id"`
 	LineNumber uint32    `json:"line_number" ch:"line_number"`
 	FileNumber uint16    `json:"file_number" ch:"file_number"`
-	Timestamp  time.Time `json:"timestamp" ch:"timestamp"`
-	IngestedAt time.Time `json:"ingested_at" ch:"ingested_at"`
+	Timestamp  Timestamp `json:"timestamp" ch:"timestamp"`
+	IngestedAt Timestamp `json:"ingested_at" ch:"ingested_at"`
 	LogType    LogType   `json:"log_type" ch:"log_type"`
 
 	// Correlation
@@ -124,10 +1097,21 @@ type LogEntry struct {
 	QueueTimeMS uint32 `json:"queue_time_ms" ch:"queue_time_ms"`
 	Success     bool   `json:"success" ch:"success"`
 
+	// DurationClamped is set when the logged duration was negative, after
+	// a clock adjustment during the call, and DurationMS was clamped to
+	// zero. It is counted at ingestion and not stored.
+	DurationClamped bool `json:"-" ch:"-"`
+
 	// API-specific
 	APICode string `json:"api_code,omitempty" ch:"api_code"`
 	Form    string `json:"form,omitempty" ch:"form"`
 
+	// Client is the client program that issued the API call (e.g.
+	// "Mid-tier", "Approval Server") and ClientIP the address it called
+	// from. Entries of the same RPC inherit them from the API call.
+	Client   string `json:"client,omitempty" ch:"client"`
+	ClientIP string `json:"client_ip,omitempty" ch:"client_ip"`
+
 	// SQL-specific
 	SQLTable     string `json:"sql_table,omitempty" ch:"sql_table"`
 	SQLStatement string `json:"sql_statement,omitempty" ch:"sql_statement"`
@@ -141,13 +1125,30 @@ type LogEntry struct {
 	// Escalation-specific
 	EscName          string     `json:"esc_name,omitempty" ch:"esc_name"`
 	EscPool          string     `json:"esc_pool,omitempty" ch:"esc_pool"`
-	ScheduledTime    *time.Time `json:"scheduled_time,omitempty" ch:"scheduled_time"`
+	ScheduledTime    *Timestamp `json:"scheduled_time,omitempty" ch:"scheduled_time"`
 	DelayMS          uint32     `json:"delay_ms,omitempty" ch:"delay_ms"`
 	ErrorEncountered bool       `json:"error_encountered,omitempty" ch:"error_encountered"`
 
 	// Raw
 	RawText      string `json:"raw_text,omitempty" ch:"raw_text"`
 	ErrorMessage string `json:"error_message,omitempty" ch:"error_message"`
+
+	// RawTextTruncated is set when RawText was cut short at ingestion. The
+	// full line is still in the uploaded file, at FileNumber and LineNumber.
+	RawTextTruncated bool `json:"raw_text_truncated,omitempty" ch:"raw_text_truncated"`
+
+	// RetentionClass is stamped from the tenant's plan at ingestion and
+	// drives the table TTL.
+	RetentionClass RetentionClass `json:"-" ch:"retention_class"`
+
+	// IsNoise marks an entry an ingestion filter rule flagged. Searches
+	// leave noise out unless asked to include it.
+	IsNoise bool `json:"is_noise,omitempty" ch:"is_noise"`
+
+	// SampleWeight is the number of entries the entry stands for: the
+	// sample rate for entries ingested as part of a sample, otherwise 1.
+	// Zero is stored as 1.
+	SampleWeight uint32 `json:"sample_weight,omitempty" ch:"sample_weight"`
 }
 
 // AIInteraction represents a user's interaction with an AI skill.
@@ -166,16 +1167,649 @@ type AIInteraction struct {
 	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
 }
 
-// SavedSearch represents a saved KQL search query.
-type SavedSearch struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
-	UserID    string    `json:"user_id" db:"user_id"`
-	Name      string    `json:"name" db:"name"`
-	KQLQuery  string    `json:"kql_query" db:"kql_query"`
-	Filters   []byte    `json:"filters" db:"filters"`
-	IsPinned  bool      `json:"is_pinned" db:"is_pinned"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+// SavedSearch represents a saved KQL search query.
+type SavedSearch struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	KQLQuery  string    `json:"kql_query" db:"kql_query"`
+	Filters   []byte    `json:"filters" db:"filters"`
+	IsPinned  bool      `json:"is_pinned" db:"is_pinned"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ExportStatus represents the lifecycle state of an asynchronous search export.
+type ExportStatus string
+
+const (
+	ExportStatusQueued   ExportStatus = "queued"
+	ExportStatusRunning  ExportStatus = "running"
+	ExportStatusComplete ExportStatus = "complete"
+	ExportStatusFailed   ExportStatus = "failed"
+	ExportStatusExpired  ExportStatus = "expired"
+)
+
+// SearchExport is a search result set exported by the worker to a compressed
+// object in S3. Query holds the storage.SearchQuery that produced it.
+// DownloadURL is only populated on read, once the export is complete.
+type SearchExport struct {
+	ID           uuid.UUID       `json:"id" db:"id"`
+	TenantID     uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	JobID        uuid.UUID       `json:"job_id" db:"job_id"`
+	UserID       string          `json:"user_id" db:"user_id"`
+	Status       ExportStatus    `json:"status" db:"status"`
+	Format       string          `json:"format" db:"format"`
+	Query        json.RawMessage `json:"query" db:"query"`
+	ProgressPct  int             `json:"progress_pct" db:"progress_pct"`
+	RowCount     int64           `json:"row_count" db:"row_count"`
+	SizeBytes    int64           `json:"size_bytes" db:"size_bytes"`
+	S3Key        *string         `json:"-" db:"s3_key"`
+	ErrorMessage *string         `json:"error_message,omitempty" db:"error_message"`
+	DownloadURL  string          `json:"download_url,omitempty" db:"-"`
+	URLExpiresAt *time.Time      `json:"url_expires_at,omitempty" db:"-"`
+	ExpiresAt    time.Time       `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at" db:"updated_at"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+
+	// Provenance is recorded once the export is complete. Reads carry its
+	// summary; GET /exports/{id}/provenance returns it whole.
+	Provenance        *ArtifactProvenance `json:"-" db:"provenance"`
+	ProvenanceSummary *ProvenanceSummary  `json:"provenance,omitempty" db:"-"`
+
+	// RegeneratedFrom is the export whose provenance this one replays.
+	RegeneratedFrom *uuid.UUID `json:"regenerated_from,omitempty" db:"regenerated_from"`
+}
+
+// ArtifactProvenance records what an exported artifact was generated from:
+// its query, the analyses it read and the state of their data, and who
+// asked for it. A copy is embedded in the artifact itself, without
+// ContentHash, the SHA-256 of the artifact as stored, which cannot be part
+// of what it hashes. An artifact is a function of its provenance: generated
+// again from it while the data is unchanged, it is byte for byte the same.
+type ArtifactProvenance struct {
+	Format        string               `json:"format"`
+	Query         json.RawMessage      `json:"query,omitempty"`
+	Analyses      []ProvenanceAnalysis `json:"analyses"`
+	ParserVersion string               `json:"parser_version"`
+	GeneratedBy   string               `json:"generated_by"`
+	GeneratedAt   time.Time            `json:"generated_at"`
+	ContentHash   string               `json:"content_hash,omitempty"`
+}
+
+// ProvenanceAnalysis is an analysis an artifact was generated from and
+// when it completed, which dates its data.
+type ProvenanceAnalysis struct {
+	JobID       uuid.UUID  `json:"job_id"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ProvenanceSummary is the provenance of an export shown in listings.
+type ProvenanceSummary struct {
+	AnalysisIDs []uuid.UUID `json:"analysis_ids"`
+	GeneratedBy string      `json:"generated_by"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	ContentHash string      `json:"content_hash,omitempty"`
+}
+
+// Summary returns the summary of p, or nil when p is nil.
+func (p *ArtifactProvenance) Summary() *ProvenanceSummary {
+	if p == nil {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(p.Analyses))
+	for i, a := range p.Analyses {
+		ids[i] = a.JobID
+	}
+	return &ProvenanceSummary{AnalysisIDs: ids, GeneratedBy: p.GeneratedBy, GeneratedAt: p.GeneratedAt, ContentHash: p.ContentHash}
+}
+
+// Embedded returns the copy of p embedded in its artifact: p without its
+// content hash.
+func (p ArtifactProvenance) Embedded() ArtifactProvenance {
+	p.ContentHash = ""
+	return p
+}
+
+// ProvenanceField is one labelled value of a provenance record, as written
+// into artifacts that are not JSON.
+type ProvenanceField struct {
+	Name  string
+	Value string
+}
+
+// Fields returns p as labelled values in a fixed order, with one
+// "analysis" field per analysis.
+func (p ArtifactProvenance) Fields() []ProvenanceField {
+	fields := []ProvenanceField{{"format", p.Format}}
+	if len(p.Query) > 0 {
+		fields = append(fields, ProvenanceField{"query", string(p.Query)})
+	}
+	for _, a := range p.Analyses {
+		v := a.JobID.String()
+		if a.CompletedAt != nil {
+			v += " completed " + a.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		fields = append(fields, ProvenanceField{"analysis", v})
+	}
+	fields = append(fields,
+		ProvenanceField{"parser_version", p.ParserVersion},
+		ProvenanceField{"generated_by", p.GeneratedBy},
+		ProvenanceField{"generated_at", p.GeneratedAt.UTC().Format(time.RFC3339)},
+	)
+	if p.ContentHash != "" {
+		fields = append(fields, ProvenanceField{"content_hash", p.ContentHash})
+	}
+	return fields
+}
+
+// Export formats of comparison exports. Their Query holds a
+// ComparisonExportQuery and JobID is the candidate analysis.
+const (
+	ExportFormatComparisonHTML = "comparison_html"
+	ExportFormatComparisonZIP  = "comparison_zip"
+)
+
+// ExportFormatTenantBundle is the export of everything stored for a tenant
+// as a tar.gz bundle. Its Query holds a TenantExportQuery, JobID is
+// uuid.Nil and RowCount is the number of analyses bundled.
+const ExportFormatTenantBundle = "tenant_bundle"
+
+// TenantBundleVersion is the version of the tenant bundle layout described
+// by its manifest. It changes whenever a file is added, moved or changes
+// shape.
+const TenantBundleVersion = 1
+
+// TenantExportQuery is the Query of a tenant bundle export.
+type TenantExportQuery struct {
+	// IncludeEntries adds the log entries of each analysis as gzip-compressed
+	// NDJSON. EntryJobIDs restricts them to the listed analyses.
+	IncludeEntries bool        `json:"include_entries"`
+	EntryJobIDs    []uuid.UUID `json:"entry_job_ids,omitempty"`
+
+	// MaxEntriesPerAnalysis caps the entries exported per analysis below
+	// the worker's export row cap; 0 leaves only the worker's cap.
+	MaxEntriesPerAnalysis int64 `json:"max_entries_per_analysis,omitempty"`
+}
+
+// TenantBundleManifest is the manifest.json at the root of a tenant bundle.
+// It lists every other file of the bundle with its size and checksum.
+type TenantBundleManifest struct {
+	Format      string             `json:"format"`
+	Version     int                `json:"version"`
+	TenantID    uuid.UUID          `json:"tenant_id"`
+	ExportID    uuid.UUID          `json:"export_id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Analyses    int                `json:"analyses"`
+	LogEntries  int64              `json:"log_entries"`
+	Annotations int                `json:"annotations"`
+	Files       []TenantBundleFile `json:"files"`
+}
+
+// TenantBundleFile is one file of a tenant bundle. Rows is the number of
+// records of NDJSON and JSON array files.
+type TenantBundleFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+	Rows      int64  `json:"rows,omitempty"`
+}
+
+// ThresholdScope selects the log entries a threshold rule is evaluated over.
+type ThresholdScope string
+
+const (
+	ThresholdScopeGlobal  ThresholdScope = "global"
+	ThresholdScopeForm    ThresholdScope = "form"
+	ThresholdScopeQueue   ThresholdScope = "queue"
+	ThresholdScopeLogType ThresholdScope = "log_type"
+)
+
+// ThresholdMetric is the measurement a threshold rule compares.
+type ThresholdMetric string
+
+const (
+	ThresholdMetricErrorRate ThresholdMetric = "error_rate" // Percentage of failed entries
+	ThresholdMetricP95       ThresholdMetric = "p95"        // 95th percentile duration in ms
+	ThresholdMetricAvg       ThresholdMetric = "avg"        // Average duration in ms
+	ThresholdMetricMaxGap    ThresholdMetric = "max_gap"    // Longest silence between entries in ms
+)
+
+// ThresholdComparator is how the observed metric is compared to the rule value.
+type ThresholdComparator string
+
+const (
+	ThresholdComparatorGT  ThresholdComparator = "gt"
+	ThresholdComparatorGTE ThresholdComparator = "gte"
+	ThresholdComparatorLT  ThresholdComparator = "lt"
+	ThresholdComparatorLTE ThresholdComparator = "lte"
+)
+
+// ThresholdSeverity classifies a violation.
+type ThresholdSeverity string
+
+const (
+	ThresholdSeverityInfo     ThresholdSeverity = "info"
+	ThresholdSeverityWarning  ThresholdSeverity = "warning"
+	ThresholdSeverityCritical ThresholdSeverity = "critical"
+)
+
+// ThresholdRule is a tenant-defined SLO evaluated against every completed
+// job, e.g. "error_rate on form HPD:Help Desk gt 0.5". A rule is violated
+// when "observed <comparator> value" holds.
+type ThresholdRule struct {
+	ID         uuid.UUID           `json:"id" db:"id"`
+	TenantID   uuid.UUID           `json:"tenant_id" db:"tenant_id"`
+	Name       string              `json:"name" db:"name"`
+	Scope      ThresholdScope      `json:"scope" db:"scope"`
+	ScopeValue string              `json:"scope_value,omitempty" db:"scope_value"`
+	Metric     ThresholdMetric     `json:"metric" db:"metric"`
+	Comparator ThresholdComparator `json:"comparator" db:"comparator"`
+	Value      float64             `json:"value" db:"value"`
+	Severity   ThresholdSeverity   `json:"severity" db:"severity"`
+	Enabled    bool                `json:"enabled" db:"enabled"`
+	CreatedAt  time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at" db:"updated_at"`
+}
+
+// ThresholdMetrics are the observed values for one rule scope of a job.
+type ThresholdMetrics struct {
+	EntryCount int64   `json:"entry_count"`
+	ErrorRate  float64 `json:"error_rate"` // Percentage, 0-100
+	P95MS      float64 `json:"p95_ms"`
+	AvgMS      float64 `json:"avg_ms"`
+	MaxGapMS   float64 `json:"max_gap_ms"`
+}
+
+// ThresholdViolation records a rule that was violated by a job. Rule fields
+// are copied so the record stays meaningful after the rule is edited.
+type ThresholdViolation struct {
+	ID         uuid.UUID           `json:"id" db:"id"`
+	TenantID   uuid.UUID           `json:"tenant_id" db:"tenant_id"`
+	JobID      uuid.UUID           `json:"job_id" db:"job_id"`
+	RuleID     uuid.UUID           `json:"rule_id" db:"rule_id"`
+	RuleName   string              `json:"rule_name" db:"rule_name"`
+	Scope      ThresholdScope      `json:"scope" db:"scope"`
+	ScopeValue string              `json:"scope_value,omitempty" db:"scope_value"`
+	Metric     ThresholdMetric     `json:"metric" db:"metric"`
+	Comparator ThresholdComparator `json:"comparator" db:"comparator"`
+	Threshold  float64             `json:"threshold" db:"threshold"`
+	Observed   float64             `json:"observed" db:"observed"`
+	Severity   ThresholdSeverity   `json:"severity" db:"severity"`
+	CreatedAt  time.Time           `json:"created_at" db:"created_at"`
+}
+
+// IngestionFilterOperator compares an entry field with a rule value.
+type IngestionFilterOperator string
+
+const (
+	IngestionFilterEquals   IngestionFilterOperator = "equals"
+	IngestionFilterPrefix   IngestionFilterOperator = "prefix"
+	IngestionFilterSuffix   IngestionFilterOperator = "suffix"
+	IngestionFilterContains IngestionFilterOperator = "contains"
+)
+
+// IngestionFilterAction is what happens to the entries a rule matches.
+type IngestionFilterAction string
+
+const (
+	// IngestionFilterDrop keeps matched entries out of ClickHouse.
+	IngestionFilterDrop IngestionFilterAction = "drop"
+	// IngestionFilterFlag stores matched entries flagged as noise.
+	IngestionFilterFlag IngestionFilterAction = "flag"
+)
+
+// IngestionFilterRule is a tenant-defined rule applied to every entry before
+// it is stored, e.g. "user equals HealthCheckUser". Monitoring probes and
+// synthetic users would otherwise drown real activity in the aggregates.
+type IngestionFilterRule struct {
+	ID            uuid.UUID               `json:"id" db:"id"`
+	TenantID      uuid.UUID               `json:"tenant_id" db:"tenant_id"`
+	Name          string                  `json:"name" db:"name"`
+	Field         string                  `json:"field" db:"field"`
+	Operator      IngestionFilterOperator `json:"operator" db:"operator"`
+	Value         string                  `json:"value" db:"value"`
+	CaseSensitive bool                    `json:"case_sensitive" db:"case_sensitive"`
+	Action        IngestionFilterAction   `json:"action" db:"action"`
+	Enabled       bool                    `json:"enabled" db:"enabled"`
+	CreatedAt     time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at" db:"updated_at"`
+}
+
+// IngestionFilterMatch counts the entries of a job one ingestion filter
+// rule matched. Rule fields are copied so the count stays meaningful after
+// the rule is edited.
+type IngestionFilterMatch struct {
+	RuleID   uuid.UUID             `json:"rule_id"`
+	RuleName string                `json:"rule_name"`
+	Action   IngestionFilterAction `json:"action"`
+	Matched  int64                 `json:"matched"`
+}
+
+// AnalysisLinkType is the kind of record an analysis is linked to.
+type AnalysisLinkType string
+
+const (
+	AnalysisLinkJira       AnalysisLinkType = "jira"
+	AnalysisLinkServiceNow AnalysisLinkType = "servicenow"
+	AnalysisLinkURL        AnalysisLinkType = "url"
+)
+
+// LinkStatusUnknown is the status of a Jira or ServiceNow link whose
+// record could not be fetched, or was not yet.
+const LinkStatusUnknown = "unknown"
+
+// AnalysisLink ties an analysis to the incident it investigates in an
+// external system: a Jira issue key, a ServiceNow record number or a URL.
+// Status and Summary are synced from the tenant's ticketing integration,
+// when it has one for the type.
+type AnalysisLink struct {
+	ID             uuid.UUID        `json:"id" db:"id"`
+	TenantID       uuid.UUID        `json:"tenant_id" db:"tenant_id"`
+	JobID          uuid.UUID        `json:"job_id" db:"job_id"`
+	Type           AnalysisLinkType `json:"type" db:"type"`
+	ExternalKey    string           `json:"external_key" db:"external_key"`
+	Title          string           `json:"title,omitempty" db:"title"`
+	URL            string           `json:"url,omitempty" db:"-"`
+	Status         string           `json:"status,omitempty" db:"status"`
+	Summary        string           `json:"summary,omitempty" db:"summary"`
+	StatusSyncedAt *time.Time       `json:"status_synced_at,omitempty" db:"status_synced_at"`
+	CreatedBy      string           `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+}
+
+// TicketingIntegration is a tenant's connection to its Jira or ServiceNow
+// instance, used to validate the links to it and sync their status. The
+// token is stored sealed and never returned.
+type TicketingIntegration struct {
+	TenantID  uuid.UUID        `json:"tenant_id" db:"tenant_id"`
+	Type      AnalysisLinkType `json:"type" db:"type"`
+	BaseURL   string           `json:"base_url" db:"base_url"`
+	Username  string           `json:"username,omitempty" db:"username"`
+	Sealed    []byte           `json:"-" db:"token_sealed"`
+	Enabled   bool             `json:"enabled" db:"enabled"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// LocalUserRole is the role of a local user in its tenant.
+type LocalUserRole string
+
+const (
+	LocalUserAdmin  LocalUserRole = "admin"
+	LocalUserMember LocalUserRole = "member"
+)
+
+// LocalUser is a user of the local auth provider, which deployments without
+// access to Clerk sign in with.
+type LocalUser struct {
+	ID           uuid.UUID     `json:"id" db:"id"`
+	TenantID     uuid.UUID     `json:"tenant_id" db:"tenant_id"`
+	Email        string        `json:"email" db:"email"`
+	PasswordHash string        `json:"-" db:"password_hash"`
+	Role         LocalUserRole `json:"role" db:"role"`
+	Disabled     bool          `json:"disabled" db:"disabled"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	LastLoginAt  *time.Time    `json:"last_login_at,omitempty" db:"last_login_at"`
+}
+
+// TenantMigrationStatus is the state of a tenant migration between
+// ClickHouse clusters.
+type TenantMigrationStatus string
+
+const (
+	TenantMigrationRunning   TenantMigrationStatus = "running"
+	TenantMigrationFailed    TenantMigrationStatus = "failed"
+	TenantMigrationCompleted TenantMigrationStatus = "completed"
+)
+
+// TenantMigration copies the log entries of a tenant to another ClickHouse
+// cluster, which the tenant is routed to once every chunk is verified.
+// ChunkLines is fixed when it starts so that a resumed migration splits jobs
+// into the same chunks.
+type TenantMigration struct {
+	ID            uuid.UUID             `json:"id" db:"id"`
+	TenantID      uuid.UUID             `json:"tenant_id" db:"tenant_id"`
+	SourceCluster string                `json:"source_cluster" db:"source_cluster"`
+	TargetCluster string                `json:"target_cluster" db:"target_cluster"`
+	ChunkLines    int                   `json:"chunk_lines" db:"chunk_lines"`
+	Status        TenantMigrationStatus `json:"status" db:"status"`
+	Error         string                `json:"error,omitempty" db:"error"`
+	CreatedAt     time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at" db:"updated_at"`
+	CompletedAt   *time.Time            `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// TenantMigrationChunk is a chunk of a job copied to the target cluster:
+// the entries of a table with line numbers from LineStart, verified to have
+// the row count and hash sum they have on the source.
+type TenantMigrationChunk struct {
+	MigrationID uuid.UUID `json:"migration_id" db:"migration_id"`
+	TableName   string    `json:"table_name" db:"table_name"`
+	JobID       string    `json:"job_id" db:"job_id"`
+	LineStart   int64     `json:"line_start" db:"line_start"`
+	Rows        int64     `json:"rows" db:"rows"`
+	Checksum    uint64    `json:"checksum" db:"checksum"`
+	VerifiedAt  time.Time `json:"verified_at" db:"verified_at"`
+}
+
+// InFlightQuery is a search or analytics request holding one of its
+// tenant's query slots. ID is the X-Query-ID of the request and prefixes the
+// ClickHouse query IDs of its statements.
+type InFlightQuery struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	JobID     string    `json:"job_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// InFlightUpload is a file upload holding one of its tenant's upload slots.
+// ContentLength is that of the request, or -1 when it is not known.
+type InFlightUpload struct {
+	ID            string    `json:"id"`
+	TenantID      string    `json:"tenant_id"`
+	UserID        string    `json:"user_id,omitempty"`
+	ContentLength int64     `json:"content_length"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// IdempotencyRecord is the stored outcome of a mutating request sent with
+// an Idempotency-Key. Keys are scoped to a tenant and an endpoint, the
+// method and route of the request. StatusCode is 0 while the first request
+// with the key is in flight; its claim on the key lapses at LockedUntil.
+type IdempotencyRecord struct {
+	TenantID     uuid.UUID `json:"tenant_id"`
+	Endpoint     string    `json:"endpoint"`
+	Key          string    `json:"key"`
+	RequestHash  string    `json:"request_hash"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ResponseBody []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	LockedUntil  time.Time `json:"locked_until"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// UploadLimits is the state of the upload limits, of one tenant or, in the
+// usage of every tenant, of the API replica as a whole. InFlight is counted
+// across replicas and is nil when it could not be read; the bandwidth
+// figures are those of the replica that served the request, whose share of
+// the global ceiling is GlobalBytesPerSec. A rate of 0 is not shaped.
+type UploadLimits struct {
+	MaxConcurrent     int              `json:"max_concurrent"`
+	InFlight          *int             `json:"in_flight,omitempty"`
+	Uploads           []InFlightUpload `json:"uploads,omitempty"`
+	BytesPerSec       int64            `json:"bytes_per_sec"`
+	BurstBytes        int64            `json:"burst_bytes"`
+	AvailableBytes    *int64           `json:"available_bytes,omitempty"`
+	GlobalBytesPerSec int64            `json:"global_bytes_per_sec"`
+	GlobalAvailable   *int64           `json:"global_available_bytes,omitempty"`
+	ActiveStreams     int              `json:"active_streams"`
+	FailOpenTotal     int64            `json:"fail_open_total"`
+}
+
+// DigestSubscription is a tenant's opt-in to the daily analysis digest.
+// The digest covering the previous local day is sent once the local time in
+// Timezone reaches SendHour.
+type DigestSubscription struct {
+	TenantID      uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	Enabled       bool       `json:"enabled" db:"enabled"`
+	Recipients    []string   `json:"recipients" db:"recipients"`
+	SendHour      int        `json:"send_hour" db:"send_hour"`
+	Timezone      string     `json:"timezone" db:"timezone"`
+	LastPeriodEnd *time.Time `json:"last_period_end,omitempty" db:"last_period_end"` // End of the last day a digest was produced for
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Preferences is the preference document of one namespace for a user, or
+// the tenant defaults of the namespace when UserID is empty. Revision starts
+// at 1 and is incremented on every write; 0 means nothing is stored.
+type Preferences struct {
+	TenantID      uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	UserID        string          `json:"user_id,omitempty" db:"user_id"`
+	Namespace     string          `json:"namespace" db:"namespace"`
+	SchemaVersion int             `json:"schema_version" db:"schema_version"`
+	Document      json.RawMessage `json:"document" db:"document"`
+	Revision      int             `json:"revision" db:"revision"`
+	UpdatedBy     string          `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt     *time.Time      `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// WorkspaceSharing is who can see and change an investigation workspace
+// besides its owner.
+type WorkspaceSharing string
+
+const (
+	// WorkspacePrivate workspaces are seen by their owner only.
+	WorkspacePrivate WorkspaceSharing = "private"
+	// WorkspaceReadOnly workspaces are seen by the whole tenant and changed
+	// by their owner only.
+	WorkspaceReadOnly WorkspaceSharing = "read_only"
+	// WorkspaceCollaborative workspaces are seen and changed by the whole
+	// tenant.
+	WorkspaceCollaborative WorkspaceSharing = "collaborative"
+)
+
+// Workspace is a named investigation workspace of an analysis: the state an
+// analyst built up while investigating it, kept so it survives a reload and
+// can be handed over. State is a WorkspaceState document in the layout of
+// SchemaVersion; Version starts at 1 and is incremented on every update.
+type Workspace struct {
+	ID            uuid.UUID        `json:"id" db:"id"`
+	TenantID      uuid.UUID        `json:"tenant_id" db:"tenant_id"`
+	JobID         uuid.UUID        `json:"job_id" db:"job_id"`
+	Name          string           `json:"name" db:"name"`
+	OwnerID       string           `json:"owner_id" db:"owner_id"`
+	Sharing       WorkspaceSharing `json:"sharing" db:"sharing"`
+	SchemaVersion int              `json:"schema_version" db:"schema_version"`
+	State         json.RawMessage  `json:"state" db:"state"`
+	Version       int              `json:"version" db:"version"`
+	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at" db:"updated_at"`
+	UpdatedBy     string           `json:"updated_by,omitempty" db:"updated_by"`
+}
+
+// WorkspaceState is the state document of a workspace. Entries are pinned
+// by entry ID, which is derived from the log line, so pins survive the
+// analysis being reprocessed.
+type WorkspaceState struct {
+	PinnedEntries []string             `json:"pinned_entries"`
+	Queries       []WorkspaceQuery     `json:"queries"`
+	TimeWindow    *WorkspaceTimeWindow `json:"time_window,omitempty"`
+	Forms         []string             `json:"forms"`
+	Queues        []string             `json:"queues"`
+	Notes         string               `json:"notes"`
+}
+
+// WorkspaceQuery is a KQL search kept in a workspace.
+type WorkspaceQuery struct {
+	Name  string `json:"name,omitempty"`
+	Query string `json:"query"`
+}
+
+// WorkspaceTimeWindow is the time range a workspace is zoomed to, as time
+// expressions of the search (an RFC3339 timestamp or a point of the
+// capture such as capture_end-15m).
+type WorkspaceTimeWindow struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// WorkspacePin is a pinned entry of a workspace with a summary of the
+// entry. Found is false when the analysis no longer holds the entry; the
+// summary is then empty.
+type WorkspacePin struct {
+	EntryID    string     `json:"entry_id"`
+	Found      bool       `json:"found"`
+	Timestamp  *Timestamp `json:"timestamp,omitempty"`
+	LogType    LogType    `json:"log_type,omitempty"`
+	LineNumber uint32     `json:"line_number,omitempty"`
+	Form       string     `json:"form,omitempty"`
+	Queue      string     `json:"queue,omitempty"`
+	DurationMS uint32     `json:"duration_ms,omitempty"`
+	Success    *bool      `json:"success,omitempty"`
+	Snippet    string     `json:"snippet,omitempty"`
+}
+
+// WorkspaceQueryStatus is whether a query of a workspace still parses.
+type WorkspaceQueryStatus struct {
+	Query  string `json:"query"`
+	Parses bool   `json:"parses"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WorkspaceView is a workspace with what the UI needs to render it at
+// once: a summary of each pinned entry and whether each query still
+// parses, in the order of the state document. When the entries cannot be
+// read Pins is nil, with the reason in Unavailable under "pins".
+type WorkspaceView struct {
+	Workspace
+	Pins        []WorkspacePin         `json:"pins"`
+	Queries     []WorkspaceQueryStatus `json:"query_status"`
+	Unavailable map[string]string      `json:"unavailable,omitempty"`
+}
+
+// ServerSettings is one version of the dynamic server settings: the values
+// admins set at runtime over the defaults and environment. Every change
+// stores a new version, which keeps who changed what. Version 0 means none
+// is stored.
+type ServerSettings struct {
+	Version   int                        `json:"version" db:"version"`
+	Settings  map[string]json.RawMessage `json:"settings" db:"settings"`
+	CreatedBy string                     `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt *time.Time                 `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Sources of the effective value of a server setting.
+const (
+	SettingSourceDefault = "default"
+	SettingSourceEnv     = "env"
+	SettingSourceDynamic = "dynamic"
+)
+
+// EffectiveSetting is the value a server setting has on a replica and where
+// it comes from. Default is the value without a dynamic override, from the
+// environment or built in.
+type EffectiveSetting struct {
+	Key         string `json:"key"`
+	Value       any    `json:"value"`
+	Source      string `json:"source"`
+	Default     any    `json:"default"`
+	Env         string `json:"env"`
+	Description string `json:"description"`
+}
+
+// EffectiveSettings is the response of GET /api/v1/admin/settings.
+type EffectiveSettings struct {
+	Version   int                `json:"version"`
+	UpdatedBy string             `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time         `json:"updated_at,omitempty"`
+	Settings  []EffectiveSetting `json:"settings"`
 }
 
 // MessageRole represents the sender of a message in a conversation.
@@ -237,9 +1871,16 @@ type GeneralStatistics struct {
 	UniqueUsers  int       `json:"unique_users"`
 	UniqueForms  int       `json:"unique_forms"`
 	UniqueTables int       `json:"unique_tables"`
-	LogStart     time.Time `json:"log_start"`
-	LogEnd       time.Time `json:"log_end"`
-	LogDuration  string    `json:"log_duration"`
+	LogStart     Timestamp `json:"log_start"`
+	LogEnd       Timestamp `json:"log_end"`
+	// LogDuration is the span of the log for display, e.g. "8h 30m 45s";
+	// LogDurationMS is the same span as a number.
+	LogDuration   string     `json:"log_duration"`
+	LogDurationMS DurationMS `json:"log_duration_ms"`
+
+	// ThreadCounts is the number of threads configured per queue, keyed by
+	// queue name as the preamble names it. Only v4 output reports it.
+	ThreadCounts map[string]int `json:"thread_counts,omitempty"`
 }
 
 // TopNEntry represents a single entry in a top-N ranking.
@@ -247,11 +1888,12 @@ type TopNEntry struct {
 	Rank        int       `json:"rank"`
 	LineNumber  int       `json:"line_number"`
 	FileNumber  int       `json:"file_number"`
-	Timestamp   time.Time `json:"timestamp"`
+	Timestamp   Timestamp `json:"timestamp"`
 	TraceID     string    `json:"trace_id"`
 	RPCID       string    `json:"rpc_id"`
 	Queue       string    `json:"queue"`
 	Identifier  string    `json:"identifier"`
+	APIName     string    `json:"api_name,omitempty"` // Full API name when Identifier is an API code
 	Form        string    `json:"form,omitempty"`
 	User        string    `json:"user,omitempty"`
 	DurationMS  int       `json:"duration_ms"`
@@ -262,7 +1904,7 @@ type TopNEntry struct {
 
 // TimeSeriesPoint represents a single data point in a time series.
 type TimeSeriesPoint struct {
-	Timestamp     time.Time `json:"timestamp"`
+	Timestamp     Timestamp `json:"timestamp"`
 	APICount      int       `json:"api_count"`
 	SQLCount      int       `json:"sql_count"`
 	FilterCount   int       `json:"filter_count"`
@@ -271,6 +1913,21 @@ type TimeSeriesPoint struct {
 	ErrorCount    int       `json:"error_count"`
 }
 
+// SortDirection is the order the JAR applied to a report table.
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = "ascending"
+	SortDescending SortDirection = "descending"
+)
+
+// TableSort describes how the JAR ordered a report table, e.g. "descending
+// AVG execution time".
+type TableSort struct {
+	SortedBy      string        `json:"sorted_by"`
+	SortDirection SortDirection `json:"sort_direction,omitempty"`
+}
+
 // DashboardData holds all data needed for the analysis dashboard.
 type DashboardData struct {
 	GeneralStats   GeneralStatistics         `json:"general_stats"`
@@ -281,10 +1938,58 @@ type DashboardData struct {
 	TimeSeries     []TimeSeriesPoint         `json:"time_series"`
 	Distribution   map[string]map[string]int `json:"distribution"`
 	HealthScore    *HealthScore              `json:"health_score,omitempty"`
+
+	// TopNSort holds the ordering of each top-N table, keyed by "api",
+	// "sql", "filters" and "escalations".
+	TopNSort map[string]TableSort `json:"top_n_sort,omitempty"`
+
+	// NoData lists, with the same keys, the top-N tables left empty because
+	// the capture holds no entries of their log type.
+	NoData []string `json:"no_data,omitempty"`
+
+	// FirstErrorAt is the timestamp of the earliest failed entry, so that
+	// clients can jump the time series there.
+	FirstErrorAt *Timestamp `json:"first_error_at,omitempty"`
+
+	// Markers are the events to draw over the time series.
+	Markers []TimeSeriesMarker `json:"markers,omitempty"`
+
+	// FocusWindow copies that of the job: the time range the dashboard
+	// opens on, along with the full range of the capture.
+	FocusWindow *FocusWindow `json:"focus_window,omitempty"`
+
+	// DataQuality and Reconciliation copy those of the job, so that a
+	// dashboard built on lost entries says so.
+	DataQuality    *DataQuality             `json:"data_quality,omitempty"`
+	Reconciliation *IngestionReconciliation `json:"reconciliation,omitempty"`
+
+	// Overlays are the series of the non-AR analyses of the job's incident
+	// group, set when the request asks to include the group.
+	Overlays []SourceOverlay `json:"overlays,omitempty"`
+
+	Estimate
+}
+
+// TimeSeriesMarkerRestart marks an AR Server restart.
+const TimeSeriesMarkerRestart = "restart"
+
+// TimeSeriesMarker is an event drawn over the time series. A marker with an
+// EndTime shades the window up to it, such as the warm-up after a restart.
+type TimeSeriesMarker struct {
+	Kind       string     `json:"kind"`
+	Timestamp  Timestamp  `json:"timestamp"`
+	EndTime    *Timestamp `json:"end_time,omitempty"`
+	Label      string     `json:"label"`
+	Confidence float64    `json:"confidence,omitempty"`
 }
 
 // --- Enhanced Analysis Dashboard Types ---
 
+// DurationResolutionMS is the resolution of logged durations: AR Server
+// logs them in whole milliseconds, so a call logged at zero took anywhere
+// under one millisecond.
+const DurationResolutionMS = 1
+
 // AggregateGroup represents a single aggregation group (e.g., by user, form, queue).
 type AggregateGroup struct {
 	Name         string  `json:"name"`
@@ -296,37 +2001,137 @@ type AggregateGroup struct {
 	ErrorCount   int64   `json:"error_count"`
 	ErrorRate    float64 `json:"error_rate"`
 	UniqueTraces int     `json:"unique_traces"`
+
+	// P95MS is the 95th percentile duration of every call. TimedCount
+	// counts the calls with a duration above zero, and AvgTimedMS and
+	// P95TimedMS are computed over those alone, so that calls faster than
+	// DurationResolutionMS do not drag them down.
+	P95MS      float64 `json:"p95_ms"`
+	TimedCount int64   `json:"timed_count"`
+	AvgTimedMS float64 `json:"avg_timed_ms"`
+	P95TimedMS float64 `json:"p95_timed_ms"`
 }
 
 // AggregateSection holds groups and an optional grand total for an aggregation.
 type AggregateSection struct {
 	Groups     []AggregateGroup `json:"groups"`
 	GrandTotal *AggregateGroup  `json:"grand_total,omitempty"`
+
+	// TotalGroups is set on paged sections to the number of groups matching
+	// the page's name filter, of which Groups holds one page. The grand
+	// total still covers every group of the section.
+	TotalGroups int `json:"total_groups,omitempty"`
+}
+
+// AggregateSort is a column aggregate groups can be sorted by.
+type AggregateSort string
+
+const (
+	AggregateSortCount     AggregateSort = "count"
+	AggregateSortTotalMS   AggregateSort = "total_ms"
+	AggregateSortAvgMS     AggregateSort = "avg_ms"
+	AggregateSortErrorRate AggregateSort = "error_rate"
+)
+
+// AggregateSorts lists the valid aggregate sorts.
+var AggregateSorts = []AggregateSort{AggregateSortCount, AggregateSortTotalMS, AggregateSortAvgMS, AggregateSortErrorRate}
+
+// Valid reports whether s is one of AggregateSorts.
+func (s AggregateSort) Valid() bool {
+	for _, sort := range AggregateSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// AggregatePage selects one page of the groups of every aggregate section:
+// those whose name contains Name, case-insensitively, sorted by SortBy in
+// SortOrder ("asc" or "desc") with ties broken by name.
+type AggregatePage struct {
+	Limit     int           `json:"limit"`
+	Offset    int           `json:"offset"`
+	SortBy    AggregateSort `json:"sort_by"`
+	SortOrder string        `json:"sort_order"`
+	Name      string        `json:"name,omitempty"`
 }
 
 // GapEntry represents a detected gap (idle period) in log activity.
 type GapEntry struct {
-	StartTime  time.Time `json:"start_time"`
-	EndTime    time.Time `json:"end_time"`
+	StartTime  Timestamp `json:"start_time"`
+	EndTime    Timestamp `json:"end_time"`
 	DurationMS int64     `json:"duration_ms"`
 	BeforeLine int       `json:"before_line"`
 	AfterLine  int       `json:"after_line"`
 	LogType    LogType   `json:"log_type"`
 	Queue      string    `json:"queue,omitempty"`
 	ThreadID   string    `json:"thread_id,omitempty"`
+	Hint       *GapHint  `json:"hint,omitempty"`
+
+	// Restart is the server restart the gap is the downtime of.
+	Restart *RestartEvent `json:"restart,omitempty"`
+}
+
+// GapClassification is the rule-based root-cause category of a gap.
+type GapClassification string
+
+const (
+	GapServerStall       GapClassification = "server_stall"
+	GapQueueStarvation   GapClassification = "queue_starvation"
+	GapSingleThreadBlock GapClassification = "single_thread_block"
+	GapLogRotation       GapClassification = "log_rotation"
+	GapServerRestart     GapClassification = "server_restart"
+	GapUnknown           GapClassification = "unknown"
+)
+
+// GapHint explains a gap with a classification and the evidence it was
+// derived from.
+type GapHint struct {
+	Classification   GapClassification `json:"classification"`
+	Reason           string            `json:"reason"`
+	ThreadIDs        []string          `json:"thread_ids"`
+	PreGapEntries    []GapThreadEntry  `json:"pre_gap_entries"`
+	PostGapQueueTime GapQueueTimeStats `json:"post_gap_queue_time"`
+}
+
+// GapThreadEntry is the last entry a thread logged before a gap.
+type GapThreadEntry struct {
+	ThreadID   string    `json:"thread_id"`
+	Queue      string    `json:"queue"`
+	Timestamp  Timestamp `json:"timestamp"`
+	LineNumber int       `json:"line_number"`
+	FileNumber int       `json:"file_number"`
+	LogType    LogType   `json:"log_type"`
+	Identifier string    `json:"identifier"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// GapQueueTimeStats summarises the queue time of API calls just after a gap
+// against the job-wide average.
+type GapQueueTimeStats struct {
+	Entries       int64   `json:"entries"`
+	AvgMS         float64 `json:"avg_ms"`
+	MaxMS         int64   `json:"max_ms"`
+	BaselineAvgMS float64 `json:"baseline_avg_ms"`
 }
 
 // ThreadStatsEntry holds per-thread statistics from log analysis.
 type ThreadStatsEntry struct {
-	ThreadID    string  `json:"thread_id"`
-	TotalCalls  int64   `json:"total_calls"`
-	TotalMS     int64   `json:"total_ms"`
-	AvgMS       float64 `json:"avg_ms"`
-	MaxMS       int64   `json:"max_ms"`
-	ErrorCount  int64   `json:"error_count"`
-	BusyPct     float64 `json:"busy_pct"`
-	ActiveStart string  `json:"active_start,omitempty"`
-	ActiveEnd   string  `json:"active_end,omitempty"`
+	ThreadID    string     `json:"thread_id"`
+	TotalCalls  int64      `json:"total_calls"`
+	TotalMS     int64      `json:"total_ms"`
+	AvgMS       float64    `json:"avg_ms"`
+	MaxMS       int64      `json:"max_ms"`
+	ErrorCount  int64      `json:"error_count"`
+	BusyPct     float64    `json:"busy_pct"`
+	ActiveStart *Timestamp `json:"active_start,omitempty"`
+	ActiveEnd   *Timestamp `json:"active_end,omitempty"`
+
+	// BusyPctResolved is BusyPct with every call counted for at least
+	// DurationResolutionMS, so that a thread of sub-millisecond calls does
+	// not show as idle.
+	BusyPctResolved float64 `json:"busy_pct_resolved"`
 }
 
 // ExceptionEntry represents a single exception/error occurrence from logs.
@@ -334,8 +2139,8 @@ type ExceptionEntry struct {
 	ErrorCode   string    `json:"error_code"`
 	Message     string    `json:"message"`
 	Count       int64     `json:"count"`
-	FirstSeen   time.Time `json:"first_seen"`
-	LastSeen    time.Time `json:"last_seen"`
+	FirstSeen   Timestamp `json:"first_seen"`
+	LastSeen    Timestamp `json:"last_seen"`
 	LogType     LogType   `json:"log_type"`
 	Queue       string    `json:"queue,omitempty"`
 	Form        string    `json:"form,omitempty"`
@@ -355,10 +2160,56 @@ type HealthScoreFactor struct {
 }
 
 // HealthScore represents the overall health assessment of an AR Server log.
+// ProfileVersion is the version of the tenant's health profile the score was
+// computed with; 0 is the built-in default profile.
 type HealthScore struct {
-	Score   int                 `json:"score"`
-	Status  string              `json:"status"`
-	Factors []HealthScoreFactor `json:"factors"`
+	Score          int                 `json:"score"`
+	Status         string              `json:"status"`
+	Factors        []HealthScoreFactor `json:"factors"`
+	ProfileVersion int                 `json:"profile_version"`
+}
+
+// HealthFactorName identifies a health score factor and the metric it scores.
+type HealthFactorName string
+
+const (
+	HealthFactorErrorRate        HealthFactorName = "error_rate"        // fraction of failed operations, 0-1
+	HealthFactorResponseTime     HealthFactorName = "response_time"     // average duration in ms
+	HealthFactorThreadSaturation HealthFactorName = "thread_saturation" // highest thread busy percentage
+	HealthFactorGapFrequency     HealthFactorName = "gap_frequency"     // longest gap in seconds
+)
+
+// HealthBreakpoint scores a factor: a metric below Below scores Score.
+type HealthBreakpoint struct {
+	Below float64 `json:"below"`
+	Score int     `json:"score"`
+}
+
+// HealthFactorConfig configures one factor of a health profile. Breakpoints
+// are in ascending Below order and the first one the metric is below
+// applies; a metric at or above every breakpoint scores OtherwiseScore.
+// The weight of a disabled factor is spread over the enabled factors in
+// proportion to their weights.
+type HealthFactorConfig struct {
+	Name           HealthFactorName   `json:"name"`
+	Weight         float64            `json:"weight"`
+	Disabled       bool               `json:"disabled,omitempty"`
+	Breakpoints    []HealthBreakpoint `json:"breakpoints"`
+	OtherwiseScore int                `json:"otherwise_score"`
+}
+
+// HealthProfile configures how a tenant's health score is computed. Scores
+// below RedBelow are red, scores above GreenAbove green and the rest yellow.
+// Stored profiles are immutable and versioned from 1; version 0 is the
+// built-in default.
+type HealthProfile struct {
+	TenantID   uuid.UUID            `json:"tenant_id"`
+	Version    int                  `json:"version"`
+	Factors    []HealthFactorConfig `json:"factors"`
+	RedBelow   int                  `json:"red_below"`
+	GreenAbove int                  `json:"green_above"`
+	CreatedBy  string               `json:"created_by,omitempty"`
+	CreatedAt  *time.Time           `json:"created_at,omitempty"`
 }
 
 // QueueHealthSummary provides per-queue health metrics.
@@ -400,9 +2251,16 @@ type FilterComplexityData struct {
 
 // AggregatesResponse is the API response for the aggregates endpoint.
 type AggregatesResponse struct {
-	API    *AggregateSection `json:"api,omitempty"`
-	SQL    *AggregateSection `json:"sql,omitempty"`
-	Filter *AggregateSection `json:"filter,omitempty"`
+	API           *AggregateSection `json:"api,omitempty"`
+	APIByClient   *AggregateSection `json:"api_by_client,omitempty"`
+	APIByClientIP *AggregateSection `json:"api_by_client_ip,omitempty"`
+	SQL           *AggregateSection `json:"sql,omitempty"`
+	Filter        *AggregateSection `json:"filter,omitempty"`
+
+	// Page is the page applied to the sections; nil when they are whole.
+	Page *AggregatePage `json:"page,omitempty"`
+
+	Estimate
 }
 
 // ExceptionsResponse is the API response for the exceptions endpoint.
@@ -411,18 +2269,297 @@ type ExceptionsResponse struct {
 	TotalCount int64              `json:"total_count"`
 	ErrorRates map[string]float64 `json:"error_rates"`
 	TopCodes   []string           `json:"top_codes"`
+
+	// Estimate labels ErrorRates; failed entries are never sampled, so
+	// exception counts are exact.
+	Estimate
+}
+
+// ErrorHeatmapOtherCode is the error code of the row that aggregates all
+// error codes beyond the requested row limit.
+const ErrorHeatmapOtherCode = "other"
+
+// ErrorHeatmapRow holds the per-bucket error counts for one error code.
+// Counts is aligned index-for-index with ErrorHeatmapResponse.Buckets.
+type ErrorHeatmapRow struct {
+	ErrorCode  string  `json:"error_code"`
+	Counts     []int64 `json:"counts"`
+	Total      int64   `json:"total"`
+	PeakBucket int     `json:"peak_bucket"`
+	IsOther    bool    `json:"is_other,omitempty"`
+}
+
+// ErrorHeatmapResponse is the API response for the exceptions heatmap
+// endpoint: a dense error code × time bucket matrix.
+type ErrorHeatmapResponse struct {
+	BucketSize string            `json:"bucket_size"`
+	Buckets    []Timestamp       `json:"buckets"`
+	Rows       []ErrorHeatmapRow `json:"rows"`
+	TotalCount int64             `json:"total_count"`
 }
 
 // GapsResponse is the API response for the gaps endpoint.
 type GapsResponse struct {
 	Gaps        []GapEntry           `json:"gaps"`
 	QueueHealth []QueueHealthSummary `json:"queue_health"`
+	Restarts    []RestartEvent       `json:"restarts,omitempty"`
+	Overlays    []SourceOverlay      `json:"overlays,omitempty"`
 }
 
 // ThreadStatsResponse is the API response for the thread stats endpoint.
 type ThreadStatsResponse struct {
 	Threads      []ThreadStatsEntry `json:"threads"`
 	TotalThreads int                `json:"total_threads"`
+	Capacity     *ThreadCapacity    `json:"capacity,omitempty"`
+}
+
+// QueueLoad is the load the threads of one queue carried over a capture.
+type QueueLoad struct {
+	Queue           string
+	ObservedThreads int
+	BusyMS          int64 // time spent in calls by all threads of the queue
+	SpanMS          int64 // span of the whole capture
+	// PeakMinute is the minute the queue was busiest in and PeakMinuteBusyMS
+	// the time its threads spent in calls started within it.
+	PeakMinute       time.Time
+	PeakMinuteBusyMS int64
+}
+
+// CapacityVerdict sums up how close a queue came to its thread capacity.
+type CapacityVerdict string
+
+const (
+	CapacityHealthy      CapacityVerdict = "healthy"
+	CapacityNearCapacity CapacityVerdict = "near_capacity"
+	CapacitySaturated    CapacityVerdict = "saturated"
+)
+
+// QueueCapacity compares the load of one queue with its thread capacity.
+type QueueCapacity struct {
+	Queue             string `json:"queue"`
+	ConfiguredThreads int    `json:"configured_threads"`
+	ObservedThreads   int    `json:"observed_threads"`
+	// Estimated is set when the JAR did not report the threads configured
+	// for the queue and the observed threads stand in for them.
+	Estimated bool `json:"estimated"`
+	// BusyPct is the busy time of the queue's threads as a percentage of
+	// its capacity over the whole capture.
+	BusyPct           float64    `json:"busy_pct"`
+	PeakMinute        *Timestamp `json:"peak_minute,omitempty"`
+	PeakMinuteBusyPct float64    `json:"peak_minute_busy_pct"`
+	// Verdict rates PeakMinuteBusyPct.
+	Verdict CapacityVerdict `json:"verdict"`
+}
+
+// ThreadCapacity compares the load of every queue with its capacity.
+type ThreadCapacity struct {
+	Queues []QueueCapacity `json:"queues"`
+	// Estimated is set when the JAR reported no thread counts at all.
+	Estimated bool `json:"estimated"`
+}
+
+// ThreadTimelineQuery selects the threads and the time window of a thread
+// timeline.
+type ThreadTimelineQuery struct {
+	From  time.Time
+	To    time.Time
+	Queue string // only threads of this queue when set
+	GapMS int64  // entries closer than this on a thread share a segment
+	Limit int    // busiest threads returned
+}
+
+// ThreadSegment is a stretch of contiguous activity on one thread.
+type ThreadSegment struct {
+	Start           Timestamp `json:"start"`
+	End             Timestamp `json:"end"`
+	EntryCount      int64     `json:"entry_count"`
+	ErrorCount      int64     `json:"error_count"`
+	DominantLogType LogType   `json:"dominant_log_type"`
+}
+
+// ThreadTimeline is the swimlane of one thread: its busy segments in time
+// order and its totals over the whole window.
+type ThreadTimeline struct {
+	ThreadID     string          `json:"thread_id"`
+	Queue        string          `json:"queue,omitempty"`
+	TotalEntries int64           `json:"total_entries"`
+	TotalErrors  int64           `json:"total_errors"`
+	TotalMS      int64           `json:"total_ms"`
+	Segments     []ThreadSegment `json:"segments"`
+	Truncated    bool            `json:"truncated"`
+}
+
+// ThreadTimelineResponse is the API response for the thread timeline endpoint.
+type ThreadTimelineResponse struct {
+	From    Timestamp        `json:"from"`
+	To      Timestamp        `json:"to"`
+	Queue   string           `json:"queue,omitempty"`
+	GapMS   int64            `json:"gap_ms"`
+	Threads []ThreadTimeline `json:"threads"`
+}
+
+// FirstError is the earliest failed entry of one log type, form or queue.
+type FirstError struct {
+	Key        string    `json:"key"` // the log type, form or queue
+	Timestamp  Timestamp `json:"timestamp"`
+	EntryID    string    `json:"entry_id"`
+	LineNumber uint32    `json:"line_number"`
+	FileNumber uint16    `json:"file_number"`
+	ErrorCount int64     `json:"error_count"`
+}
+
+// ErrorOnsetPoint is one point of the error onset curve: the errors seen
+// up to Timestamp, with Offset its position in the capture window from 0
+// (start) to 1 (end) and Fraction the share of all errors of the capture.
+type ErrorOnsetPoint struct {
+	Timestamp        Timestamp `json:"timestamp"`
+	Offset           float64   `json:"offset"`
+	CumulativeErrors int64     `json:"cumulative_errors"`
+	Fraction         float64   `json:"fraction"`
+}
+
+// ErrorRateCrossing is the first time the error rate over the trailing
+// window exceeded Threshold. CrossedAt is nil when it never did.
+type ErrorRateCrossing struct {
+	Threshold     float64    `json:"threshold"`
+	CrossedAt     *Timestamp `json:"crossed_at"`
+	Rate          float64    `json:"rate,omitempty"`
+	WindowEntries int64      `json:"window_entries,omitempty"`
+	WindowErrors  int64      `json:"window_errors,omitempty"`
+}
+
+// ErrorOnset records when a capture started to fail: the first failed entry
+// overall and per log type, form and queue, the cumulative error curve and
+// when the trailing error rate first exceeded each threshold. It is
+// computed when an analysis completes.
+type ErrorOnset struct {
+	JobID        string              `json:"job_id"`
+	CaptureStart Timestamp           `json:"capture_start"`
+	CaptureEnd   Timestamp           `json:"capture_end"`
+	TotalEntries int64               `json:"total_entries"`
+	TotalErrors  int64               `json:"total_errors"`
+	FirstError   *FirstError         `json:"first_error,omitempty"`
+	ByLogType    []FirstError        `json:"by_log_type"`
+	ByForm       []FirstError        `json:"by_form"`
+	ByQueue      []FirstError        `json:"by_queue"`
+	BucketMS     int64               `json:"bucket_ms"`
+	WindowMS     int64               `json:"window_ms"`
+	Curve        []ErrorOnsetPoint   `json:"curve"`
+	Thresholds   []ErrorRateCrossing `json:"thresholds"`
+	ComputedAt   Timestamp           `json:"computed_at"`
+
+	Estimate
+}
+
+// Focus window signals: the metrics whose deviation from the capture's
+// baseline singled the window out.
+const (
+	FocusSignalErrorRate   = "error_rate"
+	FocusSignalP95Duration = "p95_duration"
+	FocusSignalQueueTime   = "queue_time"
+)
+
+// FocusBucket is the activity of one time bucket of a capture, as scored
+// for its focus window.
+type FocusBucket struct {
+	Start      Timestamp `json:"start"`
+	Entries    int64     `json:"entries"`
+	Errors     int64     `json:"errors"`
+	P95MS      float64   `json:"p95_ms"`
+	AvgQueueMS float64   `json:"avg_queue_ms"`
+}
+
+// FocusMetrics are the non-empty buckets of a capture, in time order, cut
+// as the histogram cuts the capture's range.
+type FocusMetrics struct {
+	RangeStart Timestamp     `json:"range_start"`
+	RangeEnd   Timestamp     `json:"range_end"`
+	BucketSize string        `json:"bucket_size"`
+	BucketMS   int64         `json:"bucket_ms"`
+	Buckets    []FocusBucket `json:"buckets"`
+}
+
+// FocusWindow is the stretch of a capture whose error rate, p95 duration
+// and queue time deviate most from the capture's own baseline, which the
+// dashboard selects by default. FullStart and FullEnd are the range of the
+// whole capture, for expanding the selection back out.
+type FocusWindow struct {
+	Start      Timestamp `json:"start"`
+	End        Timestamp `json:"end"`
+	FullStart  Timestamp `json:"full_start"`
+	FullEnd    Timestamp `json:"full_end"`
+	BucketMS   int64     `json:"bucket_ms"`
+	Score      float64   `json:"score"`
+	Signals    []string  `json:"signals"`
+	ComputedAt Timestamp `json:"computed_at"`
+}
+
+// SQL operations reported by the SQL table drill-down, from the leading
+// keyword of the statement.
+const (
+	SQLOperationSelect = "SELECT"
+	SQLOperationInsert = "INSERT"
+	SQLOperationUpdate = "UPDATE"
+	SQLOperationDelete = "DELETE"
+	SQLOperationOther  = "OTHER"
+)
+
+// SQLScanReason says why a statement is suspected of scanning its table.
+type SQLScanReason string
+
+const (
+	// SQLScanNoWhere is a SELECT, UPDATE or DELETE with no WHERE clause.
+	SQLScanNoWhere SQLScanReason = "no_where_clause"
+	// SQLScanNonKeyFilter is a statement filtered only on columns other
+	// than the Request ID (C1), the one column AR always indexes. It is
+	// only flagged when it is also slow on average.
+	SQLScanNonKeyFilter SQLScanReason = "non_key_filter"
+)
+
+// SQLOperationStat is the load of one kind of SQL operation on a table.
+type SQLOperationStat struct {
+	Operation       string  `json:"operation"`
+	Count           int64   `json:"count"`
+	TotalDurationMS int64   `json:"total_duration_ms"`
+	AvgDurationMS   float64 `json:"avg_duration_ms"`
+}
+
+// SQLStatementGroup is a set of statements on a table sharing their first
+// 200 characters, with up to three of them in full.
+type SQLStatementGroup struct {
+	Statement       string        `json:"statement"`
+	Operation       string        `json:"operation"`
+	Count           int64         `json:"count"`
+	ErrorCount      int64         `json:"error_count"`
+	TotalDurationMS int64         `json:"total_duration_ms"`
+	AvgDurationMS   float64       `json:"avg_duration_ms"`
+	MaxDurationMS   int64         `json:"max_duration_ms"`
+	Examples        []string      `json:"examples"`
+	ScanReason      SQLScanReason `json:"scan_reason,omitempty"`
+}
+
+// SQLHourLoad is the load on a table within one hour of the day (UTC).
+type SQLHourLoad struct {
+	Hour            int   `json:"hour"`
+	Count           int64 `json:"count"`
+	TotalDurationMS int64 `json:"total_duration_ms"`
+}
+
+// SQLTableDrilldown breaks down the SQL load of a job on one table: by
+// operation, by statement, by hour of the day, and the statements
+// suspected of scanning the table.
+type SQLTableDrilldown struct {
+	JobID            string              `json:"job_id"`
+	Table            string              `json:"table"`
+	TotalCount       int64               `json:"total_count"`
+	TotalDurationMS  int64               `json:"total_duration_ms"`
+	Operations       []SQLOperationStat  `json:"operations"`
+	TopStatements    []SQLStatementGroup `json:"top_statements"`
+	HourlyLoad       []SQLHourLoad       `json:"hourly_load"`
+	FullScanSuspects []SQLStatementGroup `json:"full_scan_suspects"`
+	// Truncated is set when the table had more statement groups than were
+	// read; the rest are counted under OTHER in Operations.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // FilterComplexityResponse is the API response for the filter complexity endpoint.
@@ -430,21 +2567,74 @@ type FilterComplexityResponse struct {
 	MostExecuted      []MostExecutedFilter   `json:"most_executed"`
 	PerTransaction    []FilterPerTransaction `json:"per_transaction"`
 	TotalFilterTimeMS int64                  `json:"total_filter_time_ms"`
+	// TimeShare compares the filter time of the job with its API time, and
+	// FormTimeShare that of each form, most filter time first.
+	TimeShare     *FilterTimeShare  `json:"time_share,omitempty"`
+	FormTimeShare []FilterTimeShare `json:"form_time_share,omitempty"`
+}
+
+// FilterTimeShare is the time spent in filters next to the time of API
+// calls, for a job or one of its forms.
+type FilterTimeShare struct {
+	Form         string `json:"form,omitempty"`
+	FilterTimeMS int64  `json:"filter_time_ms"`
+	APITimeMS    int64  `json:"api_time_ms"`
+	// SharePct is the filter time as a percentage of the API time; nil
+	// without API time. Filters run by escalations are counted too, so it
+	// can exceed 100.
+	SharePct *float64 `json:"share_pct"`
+}
+
+// NewFilterTimeShare returns the time share of filterMS in apiMS.
+func NewFilterTimeShare(form string, filterMS, apiMS int64) FilterTimeShare {
+	s := FilterTimeShare{Form: form, FilterTimeMS: filterMS, APITimeMS: apiMS}
+	if apiMS > 0 {
+		pct := float64(filterMS) / float64(apiMS) * 100
+		s.SharePct = &pct
+	}
+	return s
 }
 
 // QueuedCallsResponse holds the queued API call data for a specific job.
+// Calls is the same table with the wait for a thread split from the run
+// time; QueuedAPICalls is kept for older clients.
 type QueuedCallsResponse struct {
-	JobID          string     `json:"job_id"`
-	QueuedAPICalls []TopNEntry `json:"queued_api_calls"`
-	Total          int        `json:"total"`
+	JobID          string             `json:"job_id"`
+	QueuedAPICalls []TopNEntry        `json:"queued_api_calls"`
+	Calls          []JARQueuedAPICall `json:"calls,omitempty"`
+	Sort           *TableSort         `json:"sort,omitempty"`
+	Total          int                `json:"total"`
+}
+
+// JARQueuedAPICall is one row of the JAR's longest queued API calls table.
+// The JAR logs a call when a thread picks it up, at Dispatch; it waited in
+// the queue from WaitStart for WaitMS before running for RunMS. A call
+// whose wait exceeds its run time was slow because its queue was saturated
+// rather than because of the work it did.
+type JARQueuedAPICall struct {
+	Rank       int       `json:"rank"`
+	LineNumber int       `json:"line_number"`
+	TraceID    string    `json:"trace_id"`
+	RPCID      string    `json:"rpc_id,omitempty"`
+	Queue      string    `json:"queue"`
+	API        string    `json:"api"`
+	APIName    string    `json:"api_name,omitempty"`
+	Form       string    `json:"form,omitempty"`
+	User       string    `json:"user,omitempty"`
+	WaitStart  Timestamp `json:"wait_start"`
+	Dispatch   Timestamp `json:"dispatch"`
+	WaitMS     int       `json:"wait_ms"`
+	RunMS      int       `json:"run_ms"`
+	TotalMS    int       `json:"total_ms"`
+	Success    bool      `json:"success"`
 }
 
 // DelayedEscalationEntry represents an escalation that ran later than scheduled.
 type DelayedEscalationEntry struct {
 	EscName       string     `json:"esc_name"`
 	EscPool       string     `json:"esc_pool"`
-	ScheduledTime *time.Time `json:"scheduled_time"`
-	ActualTime    time.Time  `json:"actual_time"`
+	ScheduledTime *Timestamp `json:"scheduled_time"`
+	ActualTime    Timestamp  `json:"actual_time"`
 	DelayMS       uint32     `json:"delay_ms"`
 	ThreadID      string     `json:"thread_id"`
 	TraceID       string     `json:"trace_id"`
@@ -463,12 +2653,17 @@ type DelayedEscalationsResponse struct {
 // --- JAR-Native Types (parsed directly from JAR v3.2.2 output) ---
 
 // JARGapEntry represents a single line gap or thread gap from the JAR output.
+// GapDuration is in seconds, as printed by the JAR.
 type JARGapEntry struct {
-	GapDuration float64   `json:"gap_duration"`
-	LineNumber  int       `json:"line_number"`
-	TraceID     string    `json:"trace_id"`
-	Timestamp   time.Time `json:"timestamp"`
-	Details     string    `json:"details"`
+	GapDuration   float64    `json:"gap_duration"`
+	GapDurationMS DurationMS `json:"gap_duration_ms"`
+	LineNumber    int        `json:"line_number"`
+	TraceID       string     `json:"trace_id"`
+	Timestamp     Timestamp  `json:"timestamp"`
+	Details       string     `json:"details"`
+
+	// Restart is the server restart the gap is the downtime of.
+	Restart *RestartEvent `json:"restart,omitempty"`
 }
 
 // JARGapsResponse contains both line gaps and thread gaps from the GAP ANALYSIS section.
@@ -477,20 +2672,29 @@ type JARGapsResponse struct {
 	ThreadGaps  []JARGapEntry        `json:"thread_gaps"`
 	QueueHealth []QueueHealthSummary `json:"queue_health"`
 	Source      string               `json:"source"`
+	Restarts    []RestartEvent       `json:"restarts,omitempty"`
+	Overlays    []SourceOverlay      `json:"overlays,omitempty"`
 }
 
-// JARAggregateRow represents one row in a JAR aggregate table.
+// JARAggregateRow represents one row in a JAR aggregate table. The *Time
+// fields are in seconds, as printed by the JAR; the *TimeMS fields carry
+// the same values in milliseconds.
 type JARAggregateRow struct {
-	OperationType string  `json:"operation_type"`
-	OK            int     `json:"ok"`
-	Fail          int     `json:"fail"`
-	Total         int     `json:"total"`
-	MinTime       float64 `json:"min_time"`
-	MinLine       int     `json:"min_line"`
-	MaxTime       float64 `json:"max_time"`
-	MaxLine       int     `json:"max_line"`
-	AvgTime       float64 `json:"avg_time"`
-	SumTime       float64 `json:"sum_time"`
+	OperationType string     `json:"operation_type"`
+	APIName       string     `json:"api_name,omitempty"` // Full API name in API tables
+	OK            int        `json:"ok"`
+	Fail          int        `json:"fail"`
+	Total         int        `json:"total"`
+	MinTime       float64    `json:"min_time"`
+	MinLine       int        `json:"min_line"`
+	MaxTime       float64    `json:"max_time"`
+	MaxLine       int        `json:"max_line"`
+	AvgTime       float64    `json:"avg_time"`
+	SumTime       float64    `json:"sum_time"`
+	MinTimeMS     DurationMS `json:"min_time_ms"`
+	MaxTimeMS     DurationMS `json:"max_time_ms"`
+	AvgTimeMS     DurationMS `json:"avg_time_ms"`
+	SumTimeMS     DurationMS `json:"sum_time_ms"`
 }
 
 // JARAggregateGroup represents one entity with its operation breakdowns.
@@ -502,10 +2706,11 @@ type JARAggregateGroup struct {
 
 // JARAggregateTable represents a complete aggregate section.
 type JARAggregateTable struct {
-	GroupedBy  string              `json:"grouped_by"`
-	SortedBy   string              `json:"sorted_by"`
-	Groups     []JARAggregateGroup `json:"groups"`
-	GrandTotal *JARAggregateRow    `json:"grand_total"`
+	GroupedBy     string              `json:"grouped_by"`
+	SortedBy      string              `json:"sorted_by"`
+	SortDirection SortDirection       `json:"sort_direction,omitempty"`
+	Groups        []JARAggregateGroup `json:"groups"`
+	GrandTotal    *JARAggregateRow    `json:"grand_total"`
 }
 
 // JARAggregatesResponse contains all aggregate tables parsed from JAR output.
@@ -519,17 +2724,20 @@ type JARAggregatesResponse struct {
 	Source        string             `json:"source"`
 }
 
-// JARThreadStat represents one thread's statistics within a queue.
+// JARThreadStat represents one thread's statistics within a queue. QTime
+// and TotalTime are in seconds, as printed by the JAR.
 type JARThreadStat struct {
-	Queue     string    `json:"queue"`
-	ThreadID  string    `json:"thread_id"`
-	FirstTime time.Time `json:"first_time"`
-	LastTime  time.Time `json:"last_time"`
-	Count     int       `json:"count"`
-	QCount    int       `json:"q_count"`
-	QTime     float64   `json:"q_time"`
-	TotalTime float64   `json:"total_time"`
-	BusyPct   float64   `json:"busy_pct"`
+	Queue       string     `json:"queue"`
+	ThreadID    string     `json:"thread_id"`
+	FirstTime   Timestamp  `json:"first_time"`
+	LastTime    Timestamp  `json:"last_time"`
+	Count       int        `json:"count"`
+	QCount      int        `json:"q_count"`
+	QTime       float64    `json:"q_time"`
+	TotalTime   float64    `json:"total_time"`
+	BusyPct     float64    `json:"busy_pct"`
+	QTimeMS     DurationMS `json:"q_time_ms"`
+	TotalTimeMS DurationMS `json:"total_time_ms"`
 }
 
 // JARThreadStatsResponse contains thread statistics for both API and SQL sections.
@@ -537,6 +2745,7 @@ type JARThreadStatsResponse struct {
 	APIThreads []JARThreadStat `json:"api_threads"`
 	SQLThreads []JARThreadStat `json:"sql_threads"`
 	Source     string          `json:"source"`
+	Capacity   *ThreadCapacity `json:"capacity,omitempty"`
 }
 
 // JARAPIError represents one API call that errored out.
@@ -547,7 +2756,7 @@ type JARAPIError struct {
 	API          string    `json:"api"`
 	Form         string    `json:"form"`
 	User         string    `json:"user"`
-	StartTime    time.Time `json:"start_time"`
+	StartTime    Timestamp `json:"start_time"`
 	ErrorMessage string    `json:"error_message"`
 }
 
@@ -568,41 +2777,64 @@ type JARExceptionsResponse struct {
 	Source        string              `json:"source"`
 }
 
+// NameConfidence tells how surely the full name of a name the JAR
+// truncated was recovered.
+type NameConfidence string
+
+const (
+	NameResolved   NameConfidence = "resolved"   // One complete name known for the job starts with it
+	NameAmbiguous  NameConfidence = "ambiguous"  // Several do; they are listed as candidates
+	NameUnresolved NameConfidence = "unresolved" // None does
+)
+
+// TruncatedName flags a name the JAR cut to the width of its report column
+// and marked with a trailing "`!". The name it qualifies holds the full name
+// when it was resolved, and the truncated one otherwise.
+type TruncatedName struct {
+	Truncated  string         `json:"truncated"` // As the JAR printed it, without the marker
+	Confidence NameConfidence `json:"confidence"`
+	Candidates []string       `json:"candidates,omitempty"` // Full names an ambiguous name may be
+}
+
 // JARFilterMostExecuted represents one filter in the "50 MOST EXECUTED FLTR" section.
 type JARFilterMostExecuted struct {
-	FilterName string `json:"filter_name"`
-	PassCount  int    `json:"pass_count"`
-	FailCount  int    `json:"fail_count"`
+	FilterName          string         `json:"filter_name"`
+	FilterNameTruncated *TruncatedName `json:"filter_name_truncated,omitempty"`
+	PassCount           int            `json:"pass_count"`
+	FailCount           int            `json:"fail_count"`
 }
 
 // JARFilterPerTransaction represents one entry in "50 MOST FILTERS PER TRANSACTION".
 type JARFilterPerTransaction struct {
-	LineNumber    int     `json:"line_number"`
-	TraceID       string  `json:"trace_id"`
-	FilterCount   int     `json:"filter_count"`
-	Operation     string  `json:"operation"`
-	Form          string  `json:"form"`
-	RequestID     string  `json:"request_id"`
-	FiltersPerSec float64 `json:"filters_per_sec"`
+	LineNumber    int            `json:"line_number"`
+	TraceID       string         `json:"trace_id"`
+	FilterCount   int            `json:"filter_count"`
+	Operation     string         `json:"operation"`
+	Form          string         `json:"form"`
+	FormTruncated *TruncatedName `json:"form_truncated,omitempty"`
+	RequestID     string         `json:"request_id"`
+	FiltersPerSec float64        `json:"filters_per_sec"`
 }
 
 // JARFilterExecutedPerTxn represents one entry in "50 MOST EXECUTED FLTR PER TRANSACTION".
 type JARFilterExecutedPerTxn struct {
-	LineNumber int    `json:"line_number"`
-	TraceID    string `json:"trace_id"`
-	FilterName string `json:"filter_name"`
-	PassCount  int    `json:"pass_count"`
-	FailCount  int    `json:"fail_count"`
+	LineNumber          int            `json:"line_number"`
+	TraceID             string         `json:"trace_id"`
+	FilterName          string         `json:"filter_name"`
+	FilterNameTruncated *TruncatedName `json:"filter_name_truncated,omitempty"`
+	PassCount           int            `json:"pass_count"`
+	FailCount           int            `json:"fail_count"`
 }
 
 // JARFilterLevel represents one entry in "50 MOST FILTER LEVELS IN TRANSACTIONS".
 type JARFilterLevel struct {
-	LineNumber  int    `json:"line_number"`
-	TraceID     string `json:"trace_id"`
-	FilterLevel int    `json:"filter_level"`
-	Operation   string `json:"operation"`
-	Form        string `json:"form"`
-	RequestID   string `json:"request_id"`
+	LineNumber    int            `json:"line_number"`
+	TraceID       string         `json:"trace_id"`
+	FilterLevel   int            `json:"filter_level"`
+	Operation     string         `json:"operation"`
+	Form          string         `json:"form"`
+	FormTruncated *TruncatedName `json:"form_truncated,omitempty"`
+	RequestID     string         `json:"request_id"`
 }
 
 // JARFilterComplexityResponse contains all 5 filter sub-sections from JAR output.
@@ -621,6 +2853,79 @@ type JARAPIAbbreviation struct {
 	FullName     string `json:"full_name"`
 }
 
+// APILegendResponse is the API response for the API abbreviation legend of
+// an analysis. Source is "jar_parsed" when the JAR printed a legend and
+// "static" when only the built-in names are available.
+type APILegendResponse struct {
+	JobID  string            `json:"job_id"`
+	Source string            `json:"source"`
+	Legend map[string]string `json:"legend"`
+}
+
+// EntryExplanation joins a log entry with what a support engineer would
+// otherwise look up by hand. Each enrichment is nil when its source has
+// nothing to say, with the reason in Unavailable keyed by section name
+// ("api", "error", "trace", "duration").
+type EntryExplanation struct {
+	Entry       LogEntry            `json:"entry"`
+	API         *EntryAPIContext    `json:"api"`
+	Error       *ARErrorExplanation `json:"error"`
+	Trace       *EntryTraceContext  `json:"trace"`
+	Duration    *DurationPercentile `json:"duration"`
+	Unavailable map[string]string   `json:"unavailable,omitempty"`
+}
+
+// EntryAPIContext names the API call of an entry. Source is "jar_parsed"
+// or "static", as for the API legend.
+type EntryAPIContext struct {
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// ARErrorExplanation describes an AR System error code.
+type ARErrorExplanation struct {
+	Code     int    `json:"code"`
+	Category string `json:"category"`
+	Summary  string `json:"summary"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// EntryTraceContext places an entry within its trace. Position is 1-based
+// in trace order and Depth is 0 for top-level spans.
+type EntryTraceContext struct {
+	TraceID        string    `json:"trace_id"`
+	Position       int       `json:"position"`
+	TraceEntries   int       `json:"trace_entries"`
+	Depth          int       `json:"depth"`
+	SiblingFilters int       `json:"sibling_filters"`
+	PrecedingAPI   *EntryRef `json:"preceding_api,omitempty"`
+}
+
+// EntryRef points at another entry of the same job.
+type EntryRef struct {
+	EntryID    string    `json:"entry_id"`
+	APICode    string    `json:"api_code,omitempty"`
+	APIName    string    `json:"api_name,omitempty"`
+	Timestamp  Timestamp `json:"timestamp"`
+	DurationMS uint32    `json:"duration_ms"`
+}
+
+// DurationPercentile ranks a duration among the entries of the same log
+// type on the same form or SQL table. Scope is "form" or "sql_table".
+type DurationPercentile struct {
+	Scope      string  `json:"scope"`
+	Value      string  `json:"value"`
+	LogType    LogType `json:"log_type"`
+	DurationMS uint32  `json:"duration_ms"`
+	Percentile float64 `json:"percentile"`
+	Samples    int64   `json:"samples"`
+	P50MS      float64 `json:"p50_ms"`
+	P95MS      float64 `json:"p95_ms"`
+	P99MS      float64 `json:"p99_ms"`
+	Summary    string  `json:"summary,omitempty"`
+}
+
 // ParseResult wraps the DashboardData with optional section-specific data
 // populated during enhanced analysis.
 type ParseResult struct {
@@ -639,10 +2944,178 @@ type ParseResult struct {
 	JARFilters     *JARFilterComplexityResponse `json:"jar_filters,omitempty"`
 
 	// Supplementary data
-	APIAbbreviations  []JARAPIAbbreviation `json:"api_abbreviations,omitempty"`
-	QueuedAPICalls    []TopNEntry          `json:"queued_api_calls,omitempty"`
-	LoggingActivities []LoggingActivity    `json:"logging_activities,omitempty"`
-	FileMetadataList  []FileMetadata       `json:"file_metadata,omitempty"`
+	APIAbbreviations   []JARAPIAbbreviation `json:"api_abbreviations,omitempty"`
+	QueuedAPICalls     []TopNEntry          `json:"queued_api_calls,omitempty"`
+	QueuedAPICallsSort *TableSort           `json:"queued_api_calls_sort,omitempty"`
+	JARQueuedAPICalls  []JARQueuedAPICall   `json:"jar_queued_api_calls,omitempty"`
+	LoggingActivities  []LoggingActivity    `json:"logging_activities,omitempty"`
+	FileMetadataList   []FileMetadata       `json:"file_metadata,omitempty"`
+
+	// Sections is derived from the preamble counts and the sections that
+	// produced rows.
+	Sections *SectionPresence `json:"sections,omitempty"`
+
+	// Diagnostics is only collected when the report is parsed in strict mode.
+	Diagnostics *ParseDiagnostics `json:"diagnostics,omitempty"`
+
+	// Profile is the report format the text report was parsed as, and
+	// JARVersion the analyzer version stated in its banner. Warnings note
+	// an analyzer version the profile was not tested with; they are also
+	// in Sections.Warnings.
+	Profile    string   `json:"profile,omitempty"`
+	JARVersion string   `json:"jar_version,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// LogTypePresence records whether a capture holds entries of one log type.
+// IngestedCount is set once the raw entries have been stored and then
+// decides Present; until then JARCount and the JAR sections do.
+type LogTypePresence struct {
+	Present       bool   `json:"present"`
+	JARCount      int64  `json:"jar_count"`
+	IngestedCount *int64 `json:"ingested_count,omitempty"`
+}
+
+// SectionPresence records which log types, and so which dashboard sections,
+// a capture holds. Warnings note log types whose JAR count and ingested
+// entries disagree.
+type SectionPresence struct {
+	API        LogTypePresence `json:"api"`
+	SQL        LogTypePresence `json:"sql"`
+	Filter     LogTypePresence `json:"filter"`
+	Escalation LogTypePresence `json:"escalation"`
+	Warnings   []string        `json:"warnings,omitempty"`
+}
+
+// CaptureProfile is how a capture was taken: the JAR flags it was analysed
+// with, the log types logging was enabled for, the capture window and the
+// number of captured files. Zero DurationMS and FileCount are unknown.
+type CaptureProfile struct {
+	JARFlags   JARFlags `json:"jar_flags"`
+	API        bool     `json:"api"`
+	SQL        bool     `json:"sql"`
+	Filter     bool     `json:"filter"`
+	Escalation bool     `json:"escalation"`
+	DurationMS int64    `json:"duration_ms,omitempty"`
+	FileCount  int      `json:"file_count,omitempty"`
+}
+
+// CaptureDriftKind names what changed between two captures.
+type CaptureDriftKind string
+
+const (
+	CaptureDriftJARFlags  CaptureDriftKind = "jar_flags"  // A JAR flag changing the analysed entries
+	CaptureDriftLogType   CaptureDriftKind = "log_type"   // Logging of a log type was enabled or disabled
+	CaptureDriftDuration  CaptureDriftKind = "duration"   // The capture window is at least twice or half as long
+	CaptureDriftFileCount CaptureDriftKind = "file_count" // A different number of files was captured
+)
+
+// CaptureDriftWarning is one difference of a capture from the reference
+// capture. Field names the flag or log type; Previous and Current are its
+// values in the reference capture and in this one.
+type CaptureDriftWarning struct {
+	Kind     CaptureDriftKind `json:"kind"`
+	Field    string           `json:"field"`
+	Previous string           `json:"previous"`
+	Current  string           `json:"current"`
+	Message  string           `json:"message"`
+}
+
+// Reference captures a capture is checked against.
+const (
+	CaptureReferencePrevious = "previous" // The tenant's previous completed analysis
+	CaptureReferenceBaseline = "baseline" // The analysis the tenant designated
+)
+
+// CaptureDrift is how a capture differs from the reference capture it was
+// checked against. It is recorded even without warnings, so that a capture
+// found consistent can be told from one never checked.
+type CaptureDrift struct {
+	ReferenceJobID uuid.UUID             `json:"reference_job_id"`
+	Reference      string                `json:"reference"`
+	Warnings       []CaptureDriftWarning `json:"warnings"`
+	CheckedAt      Timestamp             `json:"checked_at"`
+}
+
+// Drifted reports whether d has warnings; a nil d has none.
+func (d *CaptureDrift) Drifted() bool {
+	return d != nil && len(d.Warnings) > 0
+}
+
+// CaptureDriftEvent announces an analysis whose capture drifted from its
+// reference capture.
+type CaptureDriftEvent struct {
+	TenantID uuid.UUID    `json:"tenant_id"`
+	JobID    uuid.UUID    `json:"job_id"`
+	Drift    CaptureDrift `json:"drift"`
+}
+
+// DataQuality classifies the reconciliation of a job's stored entries with
+// the counts the JAR reported.
+type DataQuality string
+
+const (
+	DataQualityOK              DataQuality = "ok"
+	DataQualityMinorDivergence DataQuality = "minor_divergence"
+	DataQualityMajorDivergence DataQuality = "major_divergence"
+)
+
+// CountReconciliation compares the entries of one log type the JAR
+// reported with those stored. Expected leaves out the entries the
+// ingestion filter rules dropped; Stored counts sampled entries by their
+// weight.
+type CountReconciliation struct {
+	LogType       string  `json:"log_type"` // "total" for the sum of the log types
+	JARCount      int64   `json:"jar_count"`
+	Dropped       int64   `json:"dropped"`
+	Expected      int64   `json:"expected"`
+	Stored        int64   `json:"stored"`
+	DivergencePct float64 `json:"divergence_pct"`
+}
+
+// IngestionReconciliation is the check, at the end of ingestion, of the
+// entries stored for a job against the JAR's General Statistics. Quality
+// is that of the most divergent count, against the thresholds in effect.
+type IngestionReconciliation struct {
+	Quality  DataQuality           `json:"quality"`
+	Counts   []CountReconciliation `json:"counts"`
+	MinorPct float64               `json:"minor_pct"`
+	MajorPct float64               `json:"major_pct"`
+
+	// NegativeDurationsClamped counts the entries logged with a negative
+	// duration, stored with a duration of zero.
+	NegativeDurationsClamped int64 `json:"negative_durations_clamped,omitempty"`
+}
+
+// ParseRowError is a table row of a JAR report that strict parsing could
+// not read, with the reason why.
+type ParseRowError struct {
+	Section string `json:"section"`
+	Line    string `json:"line"`
+	Reason  string `json:"reason"`
+}
+
+// TableCoverage compares the data rows present in one section of a JAR
+// report with the rows the parser produced from them.
+type TableCoverage struct {
+	Section     string `json:"section"`
+	RowsPresent int    `json:"rows_present"`
+	RowsParsed  int    `json:"rows_parsed"`
+}
+
+// ParseDiagnostics reports what a strict parse of a JAR report left out:
+// sections no parser recognized, rows that could not be read and the share
+// of rows parsed per table. RowErrorsDropped counts row errors beyond the
+// recorded ones.
+type ParseDiagnostics struct {
+	SectionsSeen       int             `json:"sections_seen"`
+	SectionsRecognized int             `json:"sections_recognized"`
+	SkippedSections    []string        `json:"skipped_sections,omitempty"`
+	RowErrors          []ParseRowError `json:"row_errors,omitempty"`
+	RowErrorsDropped   int             `json:"row_errors_dropped,omitempty"`
+	Tables             []TableCoverage `json:"tables,omitempty"`
+	RowsPresent        int             `json:"rows_present"`
+	RowsParsed         int             `json:"rows_parsed"`
 }
 
 // --- Logging Activity & File Metadata Types ---
@@ -650,8 +3123,8 @@ type ParseResult struct {
 // LoggingActivity represents the logging duration for one log type from JAR output.
 type LoggingActivity struct {
 	LogType        string    `json:"log_type"`
-	FirstTimestamp time.Time `json:"first_timestamp"`
-	LastTimestamp  time.Time `json:"last_timestamp"`
+	FirstTimestamp Timestamp `json:"first_timestamp"`
+	LastTimestamp  Timestamp `json:"last_timestamp"`
 	DurationMS     int64     `json:"duration_ms"`
 	EntryCount     int       `json:"entry_count"`
 }
@@ -666,12 +3139,69 @@ type LoggingActivityResponse struct {
 type FileMetadata struct {
 	FileNumber int       `json:"file_number"`
 	FileName   string    `json:"file_name"`
-	StartTime  time.Time `json:"start_time"`
-	EndTime    time.Time `json:"end_time"`
+	StartTime  Timestamp `json:"start_time"`
+	EndTime    Timestamp `json:"end_time"`
 	DurationMS int64     `json:"duration_ms"`
 	EntryCount int       `json:"entry_count"`
 }
 
+// FileClockSkew is the estimated clock offset of one captured file relative
+// to the reference file, derived from trace IDs logged in both.
+type FileClockSkew struct {
+	FileNumber    int    `json:"file_number"`
+	FileName      string `json:"file_name,omitempty"`
+	ReferenceFile int    `json:"reference_file"`
+	OffsetMS      int64  `json:"offset_ms"` // Positive when this file's clock runs ahead
+	Samples       int    `json:"samples"`
+	Corrected     bool   `json:"corrected"`
+}
+
+// ClockSkewReport lists the captured files of a multi-file analysis whose
+// clock differs from the reference file by more than ThresholdMS.
+type ClockSkewReport struct {
+	ThresholdMS int64           `json:"threshold_ms"`
+	Files       []FileClockSkew `json:"files"`
+	Warning     string          `json:"warning"`
+}
+
+// RestartSignal names a kind of evidence that the AR Server restarted.
+type RestartSignal string
+
+const (
+	RestartSignalBanner       RestartSignal = "startup_banner" // The server logged its startup
+	RestartSignalThreadReset  RestartSignal = "thread_reset"   // No thread logged on both sides
+	RestartSignalTraceEpoch   RestartSignal = "trace_epoch"    // Trace IDs moved to a new prefix
+	RestartSignalLoggingGap   RestartSignal = "logging_gap"    // Logging stopped before it
+	RestartSignalFileBoundary RestartSignal = "file_boundary"  // A captured file starts there
+)
+
+// RestartEvidence is one signal supporting a detected restart.
+type RestartEvidence struct {
+	Signal RestartSignal `json:"signal"`
+	Detail string        `json:"detail"`
+}
+
+// RestartEvent is an AR Server restart detected within a capture. Timestamp
+// is the first entry logged after the restart and DownSince the last one
+// logged before it, when logging stopped for a while. Entries up to
+// WarmupEnd run on cold caches and are left out of latency factors.
+type RestartEvent struct {
+	Timestamp  Timestamp         `json:"timestamp"`
+	DownSince  *Timestamp        `json:"down_since,omitempty"`
+	WarmupEnd  *Timestamp        `json:"warmup_end,omitempty"`
+	FileNumber int               `json:"file_number"`
+	LineNumber int               `json:"line_number"`
+	Confidence float64           `json:"confidence"` // 0-1
+	Evidence   []RestartEvidence `json:"evidence"`
+}
+
+// Explains reports whether a gap in logging from start to end is the
+// downtime of the restart: the restart came up at most slack after the
+// gap ended and not before it started.
+func (e RestartEvent) Explains(start, end time.Time, slack time.Duration) bool {
+	return !e.Timestamp.Before(start) && !e.Timestamp.After(end.Add(slack))
+}
+
 // FileMetadataResponse wraps the file metadata list.
 type FileMetadataResponse struct {
 	JobID string         `json:"job_id"`
@@ -693,7 +3223,7 @@ type SpanNode struct {
 	Children       []SpanNode             `json:"children"`
 	OnCriticalPath bool                   `json:"on_critical_path"`
 	HasError       bool                   `json:"has_error"`
-	Timestamp      time.Time              `json:"timestamp"`
+	Timestamp      Timestamp              `json:"timestamp"`
 	ThreadID       string                 `json:"thread_id"`
 	TraceID        string                 `json:"trace_id"`
 	RPCID          string                 `json:"rpc_id,omitempty"`
@@ -717,8 +3247,8 @@ type WaterfallResponse struct {
 	PrimaryUser     string         `json:"primary_user"`
 	PrimaryQueue    string         `json:"primary_queue"`
 	TypeBreakdown   map[string]int `json:"type_breakdown"`
-	TraceStart      string         `json:"trace_start"`
-	TraceEnd        string         `json:"trace_end"`
+	TraceStart      Timestamp      `json:"trace_start"`
+	TraceEnd        Timestamp      `json:"trace_end"`
 	Spans           []SpanNode     `json:"spans"`
 	FlatSpans       []SpanNode     `json:"flat_spans"`
 	CriticalPath    []string       `json:"critical_path"`
@@ -727,17 +3257,17 @@ type WaterfallResponse struct {
 
 // TransactionSummary is a single transaction in search results.
 type TransactionSummary struct {
-	TraceID          string `json:"trace_id"`
-	CorrelationType  string `json:"correlation_type"`
-	PrimaryUser      string `json:"primary_user"`
-	PrimaryForm      string `json:"primary_form"`
-	PrimaryOperation string `json:"primary_operation"`
-	TotalDurationMS  int64  `json:"total_duration_ms"`
-	SpanCount        int    `json:"span_count"`
-	ErrorCount       int    `json:"error_count"`
-	FirstTimestamp   string `json:"first_timestamp"`
-	LastTimestamp    string `json:"last_timestamp"`
-	PrimaryQueue     string `json:"primary_queue,omitempty"`
+	TraceID          string    `json:"trace_id"`
+	CorrelationType  string    `json:"correlation_type"`
+	PrimaryUser      string    `json:"primary_user"`
+	PrimaryForm      string    `json:"primary_form"`
+	PrimaryOperation string    `json:"primary_operation"`
+	TotalDurationMS  int64     `json:"total_duration_ms"`
+	SpanCount        int       `json:"span_count"`
+	ErrorCount       int       `json:"error_count"`
+	FirstTimestamp   Timestamp `json:"first_timestamp"`
+	LastTimestamp    Timestamp `json:"last_timestamp"`
+	PrimaryQueue     string    `json:"primary_queue,omitempty"`
 }
 
 // TransactionSearchResponse is the response for transaction discovery.
@@ -758,3 +3288,272 @@ type TransactionSearchParams struct {
 	Limit       int    `json:"limit,omitempty"`
 	Offset      int    `json:"offset,omitempty"`
 }
+
+// Statuses of a span of a trace diff.
+const (
+	TraceDiffMatched       = "matched"
+	TraceDiffBaselineOnly  = "baseline_only"
+	TraceDiffCandidateOnly = "candidate_only"
+)
+
+// TraceDiffSide is one trace's entry of an aligned span. OffsetMS is the
+// time from the start of its trace.
+type TraceDiffSide struct {
+	EntryID     string `json:"entry_id"`
+	LineNumber  uint32 `json:"line_number"`
+	FileNumber  uint16 `json:"file_number"`
+	OffsetMS    int64  `json:"offset_ms"`
+	DurationMS  int64  `json:"duration_ms"`
+	QueueTimeMS int64  `json:"queue_time_ms"`
+	Success     bool   `json:"success"`
+}
+
+// TraceDiffSpan is one row of the alignment of two traces: a span both
+// traces ran, with the candidate's duration minus the baseline's in
+// DeltaMS, or a span only one of them ran. Moved marks a matched span the
+// two traces ran in a different order.
+type TraceDiffSpan struct {
+	Status    string         `json:"status"`
+	Signature string         `json:"signature"`
+	LogType   LogType        `json:"log_type"`
+	Name      string         `json:"name"`
+	Baseline  *TraceDiffSide `json:"baseline,omitempty"`
+	Candidate *TraceDiffSide `json:"candidate,omitempty"`
+	DeltaMS   int64          `json:"delta_ms"`
+	Moved     bool           `json:"moved,omitempty"`
+}
+
+// TraceDiffStats are the totals of one trace of a diff, or the
+// candidate's totals minus the baseline's.
+type TraceDiffStats struct {
+	Entries        int   `json:"entries"`
+	DurationMS     int64 `json:"duration_ms"`
+	APICount       int   `json:"api_count"`
+	SQLCount       int   `json:"sql_count"`
+	SQLTimeMS      int64 `json:"sql_time_ms"`
+	FilterCount    int   `json:"filter_count"`
+	FilterTimeMS   int64 `json:"filter_time_ms"`
+	MaxQueueWaitMS int64 `json:"max_queue_wait_ms"`
+	ErrorCount     int   `json:"error_count"`
+}
+
+// TraceDiff is the response of POST /api/v1/analyses/{job_id}/traces/diff:
+// the spans of two traces of the same operation aligned, with the totals
+// of both traces and their difference. Truncated is set when a trace had
+// more entries than are compared; only its first entries were aligned.
+type TraceDiff struct {
+	BaselineJobID    string          `json:"baseline_job_id"`
+	BaselineTraceID  string          `json:"baseline_trace_id"`
+	CandidateJobID   string          `json:"candidate_job_id"`
+	CandidateTraceID string          `json:"candidate_trace_id"`
+	Matched          int             `json:"matched"`
+	BaselineOnly     int             `json:"baseline_only"`
+	CandidateOnly    int             `json:"candidate_only"`
+	Spans            []TraceDiffSpan `json:"spans"`
+	Baseline         TraceDiffStats  `json:"baseline"`
+	Candidate        TraceDiffStats  `json:"candidate"`
+	Delta            TraceDiffStats  `json:"delta"`
+	Truncated        bool            `json:"truncated,omitempty"`
+}
+
+// --- Analysis Comparison Types ---
+
+// ComparisonSection names a section of an analysis comparison.
+type ComparisonSection string
+
+const (
+	ComparisonGeneral    ComparisonSection = "general"
+	ComparisonForms      ComparisonSection = "forms"
+	ComparisonExceptions ComparisonSection = "exceptions"
+	ComparisonHealth     ComparisonSection = "health"
+	ComparisonQueues     ComparisonSection = "queues"
+)
+
+// ComparisonSections lists every comparison section in report order.
+var ComparisonSections = []ComparisonSection{
+	ComparisonGeneral, ComparisonForms, ComparisonExceptions, ComparisonHealth, ComparisonQueues,
+}
+
+// ExceptionChange classifies how an error code differs between analyses.
+type ExceptionChange string
+
+const (
+	ExceptionAppeared    ExceptionChange = "appeared"
+	ExceptionDisappeared ExceptionChange = "disappeared"
+	ExceptionRateChanged ExceptionChange = "rate_changed"
+)
+
+// ComparisonExportQuery is the Query of a comparison export.
+type ComparisonExportQuery struct {
+	BaselineJobID  uuid.UUID           `json:"baseline_job_id"`
+	CandidateJobID uuid.UUID           `json:"candidate_job_id"`
+	Sections       []ComparisonSection `json:"sections"`
+}
+
+// StatComparison is one general statistic of both analyses.
+type StatComparison struct {
+	Name      string  `json:"name"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Delta     float64 `json:"delta"`
+}
+
+// FormComparison is the API latency of one form in the candidate analysis,
+// with the baseline figures when the form appeared there. DeltaMS is the
+// change in average latency, 0 for forms new in the candidate.
+type FormComparison struct {
+	Form           string   `json:"form"`
+	CandidateCount int64    `json:"candidate_count"`
+	CandidateAvgMS float64  `json:"candidate_avg_ms"`
+	BaselineCount  int64    `json:"baseline_count"`
+	BaselineAvgMS  *float64 `json:"baseline_avg_ms,omitempty"`
+	DeltaMS        float64  `json:"delta_ms"`
+}
+
+// ExceptionComparison is an error code that appeared, disappeared or
+// changed rate between analyses. Rates are occurrences per hour of log.
+type ExceptionComparison struct {
+	ErrorCode        string          `json:"error_code"`
+	Message          string          `json:"message"`
+	Change           ExceptionChange `json:"change"`
+	BaselineCount    int64           `json:"baseline_count"`
+	CandidateCount   int64           `json:"candidate_count"`
+	BaselinePerHour  float64         `json:"baseline_per_hour"`
+	CandidatePerHour float64         `json:"candidate_per_hour"`
+}
+
+// HealthFactorComparison is one health score factor of both analyses.
+type HealthFactorComparison struct {
+	Name      string `json:"name"`
+	Baseline  *int   `json:"baseline,omitempty"`
+	Candidate *int   `json:"candidate,omitempty"`
+}
+
+// HealthComparison compares the health scores of two analyses.
+type HealthComparison struct {
+	Baseline  *HealthScore             `json:"baseline"`
+	Candidate *HealthScore             `json:"candidate"`
+	Factors   []HealthFactorComparison `json:"factors"`
+}
+
+// QueueComparison is the health of one queue in both analyses; either side
+// is nil when the queue was not active in that analysis.
+type QueueComparison struct {
+	Queue     string              `json:"queue"`
+	Baseline  *QueueHealthSummary `json:"baseline,omitempty"`
+	Candidate *QueueHealthSummary `json:"candidate,omitempty"`
+}
+
+// AnalysisComparison is the before/after comparison of two analyses. Only
+// the requested sections are populated.
+type AnalysisComparison struct {
+	Baseline    ComparedAnalysis    `json:"baseline"`
+	Candidate   ComparedAnalysis    `json:"candidate"`
+	Sections    []ComparisonSection `json:"sections"`
+	GeneratedAt Timestamp           `json:"generated_at"`
+
+	// Provenance is set on comparisons rendered for export.
+	Provenance *ArtifactProvenance `json:"provenance,omitempty"`
+
+	// CaptureDrift lists how the candidate capture was taken differently
+	// from the baseline one; such differences may explain the others.
+	CaptureDrift []CaptureDriftWarning `json:"capture_drift,omitempty"`
+
+	Stats      []StatComparison      `json:"stats,omitempty"`
+	Forms      []FormComparison      `json:"forms,omitempty"`
+	Exceptions []ExceptionComparison `json:"exceptions,omitempty"`
+	Health     *HealthComparison     `json:"health,omitempty"`
+	Queues     []QueueComparison     `json:"queues,omitempty"`
+}
+
+// ComparedAnalysis identifies one side of a comparison.
+type ComparedAnalysis struct {
+	JobID       uuid.UUID  `json:"job_id"`
+	Filename    string     `json:"filename,omitempty"`
+	CompletedAt *Timestamp `json:"completed_at,omitempty"`
+}
+
+// IncidentGroup is a lightweight grouping of the analyses of one incident,
+// typically the AR Server log with the server's access and GC logs, so that
+// their timelines can be overlaid.
+type IncidentGroup struct {
+	ID        uuid.UUID             `json:"id"`
+	TenantID  uuid.UUID             `json:"tenant_id"`
+	Name      string                `json:"name"`
+	CreatedBy string                `json:"created_by,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+	Members   []IncidentGroupMember `json:"members"`
+}
+
+// IncidentGroupMember is an analysis of an incident group.
+type IncidentGroupMember struct {
+	JobID      uuid.UUID     `json:"job_id"`
+	Filename   string        `json:"filename"`
+	SourceType LogSourceType `json:"source_type"`
+	Status     JobStatus     `json:"status"`
+	LogStart   *time.Time    `json:"log_start,omitempty"`
+	LogEnd     *time.Time    `json:"log_end,omitempty"`
+}
+
+// SourceOverlay is the timeline of a non-AR analysis to draw over that of
+// an AR Server analysis of the same incident: per minute counts of its
+// entries and their longest durations, and the longest entries themselves,
+// such as the longest GC pauses.
+type SourceOverlay struct {
+	JobID      uuid.UUID      `json:"job_id"`
+	SourceType LogSourceType  `json:"source_type"`
+	Series     []OverlayPoint `json:"series"`
+	Events     []OverlayEvent `json:"events"`
+}
+
+// OverlayPoint is one minute of one log type of an overlay.
+type OverlayPoint struct {
+	Timestamp     Timestamp `json:"timestamp"`
+	LogType       LogType   `json:"log_type"`
+	Count         int64     `json:"count"`
+	ErrorCount    int64     `json:"error_count"`
+	MaxDurationMS int64     `json:"max_duration_ms"`
+}
+
+// OverlayEvent is a single entry of an overlay, drawn as a span from
+// Timestamp for DurationMS.
+type OverlayEvent struct {
+	EntryID    string    `json:"entry_id"`
+	Timestamp  Timestamp `json:"timestamp"`
+	LogType    LogType   `json:"log_type"`
+	DurationMS int64     `json:"duration_ms"`
+	Label      string    `json:"label"`
+	Success    bool      `json:"success"`
+}
+
+// LogTypeStats describes the entries stored for one log type of a job.
+// Rows counts stored rows; Entries counts them by their sample weight, as
+// the entries of the capture they stand for.
+type LogTypeStats struct {
+	LogType LogType   `json:"log_type"`
+	Rows    int64     `json:"rows"`
+	Entries int64     `json:"entries"`
+	MinTime time.Time `json:"min_time"`
+	MaxTime time.Time `json:"max_time"`
+}
+
+// CachedKey is a Redis key holding cached data of a job, with the size of
+// its value in bytes and its remaining TTL. TTLSeconds is -1 for keys that
+// do not expire.
+type CachedKey struct {
+	Key        string `json:"key"`
+	Bytes      int64  `json:"bytes"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// SlowQuery is a ClickHouse statement from the server's query log that took
+// at least the slow query threshold. ExceptionCode is set on statements
+// that failed.
+type SlowQuery struct {
+	StartedAt     time.Time `json:"started_at"`
+	DurationMS    int64     `json:"duration_ms"`
+	ReadRows      int64     `json:"read_rows"`
+	MemoryBytes   int64     `json:"memory_bytes"`
+	ExceptionCode int32     `json:"exception_code,omitempty"`
+	Query         string    `json:"query"`
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -103,3 +104,27 @@ func TestMessage_ErrorMessage(t *testing.T) {
 	assert.Equal(t, MessageStatusError, msg.Status)
 	assert.Equal(t, "AI service unavailable", msg.ErrorMessage)
 }
+
+func TestTenant_Quotas(t *testing.T) {
+	configured := TenantQuotas{MaxFileBytes: 2 << 30}
+
+	assert.Equal(t, configured, (&Tenant{}).Quotas(configured), "full tenants get the configured quotas")
+	assert.Equal(t, configured, (*Tenant)(nil).Quotas(configured))
+
+	sandbox := &Tenant{Sandbox: true}
+	assert.Equal(t, TenantQuotas{MaxFileBytes: SandboxMaxFileBytes, MaxAnalyses: SandboxMaxAnalyses}, sandbox.Quotas(configured),
+		"sandbox quotas override looser and unlimited ones")
+
+	tight := TenantQuotas{MaxFileBytes: 1 << 20, MaxAnalyses: 3}
+	assert.Equal(t, tight, sandbox.Quotas(tight), "tighter configured quotas are kept")
+}
+
+func TestTenant_SandboxExpired(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+	sandbox := &Tenant{Sandbox: true, SandboxExpiresAt: &expires}
+
+	assert.False(t, sandbox.SandboxExpired(now))
+	assert.True(t, sandbox.SandboxExpired(expires))
+	assert.False(t, (&Tenant{SandboxExpiresAt: &expires}).SandboxExpired(expires.Add(time.Hour)), "only sandboxes expire")
+}
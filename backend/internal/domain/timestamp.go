@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the layout every timestamp in an API response is
+// written in: RFC 3339 in UTC with millisecond precision.
+const TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// legacyTimestampLayouts are accepted when decoding timestamps written by
+// older releases, e.g. cached dashboards and exported bundles.
+var legacyTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// Timestamp is a point in time as served by the API. It marshals as
+// TimestampLayout whatever the location and precision of the wrapped time,
+// and scans from Postgres and ClickHouse like time.Time.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp wraps t.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// TimestampPtr wraps t, keeping nil as nil.
+func TimestampPtr(t *time.Time) *Timestamp {
+	if t == nil {
+		return nil
+	}
+	return &Timestamp{Time: *t}
+}
+
+// TimePtr returns the wrapped time of t, keeping nil as nil.
+func (t *Timestamp) TimePtr() *time.Time {
+	if t == nil {
+		return nil
+	}
+	tt := t.Time
+	return &tt
+}
+
+// String formats the timestamp as TimestampLayout.
+func (t Timestamp) String() string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("timestamp: %w", err)
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ParseTimestamp parses s as RFC 3339 or as the "2006-01-02 15:04:05"
+// layout of older releases, which is taken to be UTC.
+func ParseTimestamp(s string) (Timestamp, error) {
+	for _, layout := range legacyTimestampLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			return Timestamp{Time: parsed}, nil
+		}
+	}
+	return Timestamp{}, fmt.Errorf("timestamp: cannot parse %q as RFC 3339", s)
+}
+
+// Scan implements sql.Scanner for Postgres and ClickHouse columns.
+func (t *Timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = Timestamp{}
+	case time.Time:
+		*t = Timestamp{Time: v}
+	case *time.Time:
+		if v == nil {
+			*t = Timestamp{}
+		} else {
+			*t = Timestamp{Time: *v}
+		}
+	case string:
+		parsed, err := ParseTimestamp(v)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	default:
+		return fmt.Errorf("timestamp: cannot scan %T", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (t Timestamp) Value() (driver.Value, error) {
+	return t.Time, nil
+}
+
+// DurationMS is a duration as served by the API: a whole number of
+// milliseconds.
+type DurationMS int64
+
+// DurationMSOf rounds d to the nearest millisecond.
+func DurationMSOf(d time.Duration) DurationMS {
+	return DurationMS(d.Round(time.Millisecond) / time.Millisecond)
+}
+
+// DurationMSFromSeconds rounds a duration in seconds to the nearest
+// millisecond.
+func DurationMSFromSeconds(s float64) DurationMS {
+	return DurationMS(math.Round(s * 1000))
+}
+
+// Duration returns d as a time.Duration.
+func (d DurationMS) Duration() time.Duration {
+	return time.Duration(d) * time.Millisecond
+}
+
+// Display formats d the way the JAR reports durations, e.g. "8h 30m 45s",
+// for responses that carry a human-readable form next to the number.
+// Durations under a second are written in milliseconds.
+func (d DurationMS) Display() string {
+	if d < 0 {
+		return "-" + (-d).Display()
+	}
+	if d < 1000 {
+		return strconv.FormatInt(int64(d), 10) + "ms"
+	}
+	total := int64(d) / 1000
+	days, rest := total/86400, total%86400
+	hours, rest := rest/3600, rest%3600
+	minutes, seconds := rest/60, rest%60
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, strconv.FormatInt(days, 10)+"d")
+	}
+	if hours > 0 {
+		parts = append(parts, strconv.FormatInt(hours, 10)+"h")
+	}
+	if minutes > 0 {
+		parts = append(parts, strconv.FormatInt(minutes, 10)+"m")
+	}
+	if seconds > 0 || len(parts) == 0 {
+		parts = append(parts, strconv.FormatInt(seconds, 10)+"s")
+	}
+	return strings.Join(parts, " ")
+}
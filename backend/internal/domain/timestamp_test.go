@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamp_MarshalJSON(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{"utc whole seconds", time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC), `"2026-02-03T10:00:00.000Z"`},
+		{"nanoseconds truncated to millis", time.Date(2026, 2, 3, 10, 0, 0, 123456789, time.UTC), `"2026-02-03T10:00:00.123Z"`},
+		{"other zone converted to utc", time.Date(2026, 2, 3, 5, 0, 0, 0, est), `"2026-02-03T10:00:00.000Z"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(NewTimestamp(tt.in))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(b))
+		})
+	}
+}
+
+func TestTimestamp_OmitEmptyPointer(t *testing.T) {
+	b, err := json.Marshal(ThreadStatsEntry{ThreadID: "T1"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "active_start")
+
+	at := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	b, err = json.Marshal(ThreadStatsEntry{ThreadID: "T1", ActiveStart: TimestampPtr(&at)})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"active_start":"2026-02-03T10:00:00.000Z"`)
+}
+
+func TestTimestamp_UnmarshalJSON(t *testing.T) {
+	want := time.Date(2026, 2, 3, 10, 0, 0, 500000000, time.UTC)
+	for _, in := range []string{
+		`"2026-02-03T10:00:00.500Z"`,
+		`"2026-02-03T11:00:00.5+01:00"`,
+		`"2026-02-03 10:00:00.5"`,
+	} {
+		var ts Timestamp
+		require.NoError(t, json.Unmarshal([]byte(in), &ts), in)
+		assert.True(t, want.Equal(ts.Time), in)
+	}
+
+	var ts Timestamp
+	require.NoError(t, json.Unmarshal([]byte(`null`), &ts))
+	assert.True(t, ts.IsZero())
+	assert.Error(t, json.Unmarshal([]byte(`"yesterday"`), &ts))
+}
+
+func TestTimestamp_Scan(t *testing.T) {
+	at := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	var ts Timestamp
+	require.NoError(t, ts.Scan(at))
+	assert.Equal(t, at, ts.Time)
+	require.NoError(t, ts.Scan(&at))
+	assert.Equal(t, at, ts.Time)
+	require.NoError(t, ts.Scan("2026-02-03 10:00:00"))
+	assert.True(t, at.Equal(ts.Time))
+	require.NoError(t, ts.Scan(nil))
+	assert.True(t, ts.IsZero())
+	assert.Error(t, ts.Scan(42))
+
+	v, err := NewTimestamp(at).Value()
+	require.NoError(t, err)
+	assert.Equal(t, at, v)
+}
+
+func TestDurationMS(t *testing.T) {
+	assert.Equal(t, DurationMS(1500), DurationMSOf(1500*time.Millisecond+400*time.Microsecond))
+	assert.Equal(t, DurationMS(12877), DurationMSFromSeconds(12.877))
+	assert.Equal(t, 1500*time.Millisecond, DurationMS(1500).Duration())
+
+	b, err := json.Marshal(GeneralStatistics{LogDuration: "8h 30m 45s", LogDurationMS: 30645000})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"log_duration":"8h 30m 45s","log_duration_ms":30645000`)
+}
+
+func TestDurationMS_Display(t *testing.T) {
+	tests := map[DurationMS]string{
+		0:         "0ms",
+		250:       "250ms",
+		12877:     "12s",
+		30645000:  "8h 30m 45s",
+		3600000:   "1h",
+		117045000: "1d 8h 30m 45s",
+		-90000:    "-1m 30s",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, in.Display(), "%d", in)
+	}
+}
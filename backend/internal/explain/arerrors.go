@@ -0,0 +1,63 @@
+package explain
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// arErrorCodeRe finds the AR error code in messages such as
+// "ARERR [302] Entry does not exist in database" or "(ARERR 623)".
+var arErrorCodeRe = regexp.MustCompile(`ARERR\s*\[?\s*(\d+)\s*\]?`)
+
+// arErrors is the error taxonomy: the AR System errors support engineers
+// meet most often in server logs, by category.
+var arErrors = map[int]domain.ARErrorExplanation{
+	90: {Category: "network", Summary: "Cannot establish a network connection to the AR System server",
+		Hint: "Check that the server is up and reachable on its RPC port from the client."},
+	91: {Category: "network", Summary: "RPC call failed",
+		Hint: "The connection dropped mid-call; look for server restarts or network resets at the same time."},
+	92: {Category: "timeout", Summary: "Timeout during database update; the operation was accepted and usually completes",
+		Hint: "Slow SQL or a saturated fast queue; compare with the SQL entries of the same trace."},
+	93: {Category: "timeout", Summary: "Timeout during data retrieval due to busy server",
+		Hint: "Look at the queue and thread sections for saturation at the time of the call."},
+	94: {Category: "timeout", Summary: "Timeout during database query; consider a more specific qualification",
+		Hint: "Usually an unindexed qualification; check the SQL statement and table of the trace."},
+	302: {Category: "data", Summary: "Entry does not exist in database",
+		Hint: "The request ID was deleted or never committed; often a race between workflow and a delete."},
+	303: {Category: "definition", Summary: "Form does not exist on server",
+		Hint: "A workflow or client refers to a renamed or deleted form."},
+	306: {Category: "data", Summary: "Value does not fall within the limits specified for the field"},
+	307: {Category: "data", Summary: "Required field (without a default) not specified"},
+	326: {Category: "data", Summary: "Required field cannot be reset to a NULL value"},
+	382: {Category: "data", Summary: "The value(s) for this entry violate a unique index",
+		Hint: "A duplicate submit; look for an earlier create with the same key in the trace."},
+	552: {Category: "database", Summary: "The SQL database operation failed",
+		Hint: "The database error follows in the message; check the SQL entries around this one."},
+	623: {Category: "authentication", Summary: "Authentication failed",
+		Hint: "Wrong password, or the authentication plug-in (AREA) rejected the user."},
+}
+
+// ARErrorCode returns the AR error code in message, if any.
+func ARErrorCode(message string) (int, bool) {
+	m := arErrorCodeRe.FindStringSubmatch(message)
+	if m == nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// LookupARError returns the taxonomy entry of an AR error code.
+func LookupARError(code int) (domain.ARErrorExplanation, bool) {
+	e, ok := arErrors[code]
+	if !ok {
+		return domain.ARErrorExplanation{}, false
+	}
+	e.Code = code
+	return e, true
+}
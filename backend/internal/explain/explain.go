@@ -0,0 +1,244 @@
+// Package explain assembles the "explain this entry" view of a log entry:
+// the API name from the abbreviation legend, the AR error from the error
+// taxonomy, the entry's place in its trace, and how its duration ranks on
+// the same form or table. Each enrichment degrades on its own, so a missing
+// source never hides the others.
+package explain
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/trace"
+)
+
+// Section names, as keys of EntryExplanation.Unavailable.
+const (
+	SectionAPI      = "api"
+	SectionError    = "error"
+	SectionTrace    = "trace"
+	SectionDuration = "duration"
+)
+
+// EntrySource reads a log entry.
+type EntrySource interface {
+	GetLogEntry(ctx context.Context, tenantID, jobID, entryID string) (*domain.LogEntry, error)
+}
+
+// LegendSource reads the API abbreviation legend of a job.
+type LegendSource interface {
+	GetJobAPILegend(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JARAPIAbbreviation, error)
+}
+
+// TraceSource reads the entries of a trace in trace order.
+type TraceSource interface {
+	GetTraceEntries(ctx context.Context, tenantID, jobID, traceID string) ([]domain.LogEntry, error)
+}
+
+// DurationSource ranks a duration among comparable entries.
+type DurationSource interface {
+	GetDurationPercentile(ctx context.Context, tenantID, jobID string, logType domain.LogType, scope, value string, durationMS uint32) (*domain.DurationPercentile, error)
+}
+
+// Explainer assembles entry explanations. It is implemented over the
+// Postgres and ClickHouse stores, which satisfy the source interfaces.
+type Explainer struct {
+	entries   EntrySource
+	legend    LegendSource
+	traces    TraceSource
+	durations DurationSource
+}
+
+// NewExplainer creates an Explainer.
+func NewExplainer(entries EntrySource, legend LegendSource, traces TraceSource, durations DurationSource) *Explainer {
+	return &Explainer{entries: entries, legend: legend, traces: traces, durations: durations}
+}
+
+// Explain returns the explanation of an entry. Only a failure to read the
+// entry itself is an error; enrichments that cannot be made are nil with
+// a reason.
+func (x *Explainer) Explain(ctx context.Context, tenantID, jobID uuid.UUID, entryID string) (*domain.EntryExplanation, error) {
+	entry, err := x.entries.GetLogEntry(ctx, tenantID.String(), jobID.String(), entryID)
+	if err != nil {
+		return nil, fmt.Errorf("explain: entry %s: %w", entryID, err)
+	}
+
+	out := &domain.EntryExplanation{Entry: *entry}
+	unavailable := func(section, reason string) {
+		if out.Unavailable == nil {
+			out.Unavailable = make(map[string]string)
+		}
+		out.Unavailable[section] = reason
+	}
+
+	legend, source := x.loadLegend(ctx, tenantID, jobID)
+
+	if api, reason := explainAPI(entry, legend, source); api != nil {
+		out.API = api
+	} else {
+		unavailable(SectionAPI, reason)
+	}
+	if arErr, reason := explainError(entry); arErr != nil {
+		out.Error = arErr
+	} else {
+		unavailable(SectionError, reason)
+	}
+	if tc, reason := x.explainTrace(ctx, tenantID, jobID, entry, legend); tc != nil {
+		out.Trace = tc
+	} else {
+		unavailable(SectionTrace, reason)
+	}
+	if d, reason := x.explainDuration(ctx, tenantID, jobID, entry); d != nil {
+		out.Duration = d
+	} else {
+		unavailable(SectionDuration, reason)
+	}
+	return out, nil
+}
+
+// loadLegend loads the job's legend. An unreadable legend resolves from the
+// static names only.
+func (x *Explainer) loadLegend(ctx context.Context, tenantID, jobID uuid.UUID) (jar.APILegend, string) {
+	entries, err := x.legend.GetJobAPILegend(ctx, tenantID, jobID)
+	if err != nil {
+		slog.Warn("api legend lookup failed, using static names", "job_id", jobID, "error", err)
+		return nil, "static"
+	}
+	if len(entries) == 0 {
+		return nil, "static"
+	}
+	return jar.NewAPILegend(entries), "jar_parsed"
+}
+
+func explainAPI(e *domain.LogEntry, legend jar.APILegend, source string) (*domain.EntryAPIContext, string) {
+	if e.APICode == "" {
+		return nil, "entry has no API code"
+	}
+	name, ok := legend.Resolve(e.APICode)
+	if !ok {
+		return nil, fmt.Sprintf("API code %s is not in the abbreviation legend", e.APICode)
+	}
+	return &domain.EntryAPIContext{Code: e.APICode, Name: name, Source: source}, ""
+}
+
+func explainError(e *domain.LogEntry) (*domain.ARErrorExplanation, string) {
+	if e.ErrorMessage == "" {
+		return nil, "entry has no error message"
+	}
+	code, ok := ARErrorCode(e.ErrorMessage)
+	if !ok {
+		return nil, "error message has no AR error code"
+	}
+	arErr, ok := LookupARError(code)
+	if !ok {
+		return nil, fmt.Sprintf("ARERR %d is not in the error taxonomy", code)
+	}
+	return &arErr, ""
+}
+
+func (x *Explainer) explainTrace(ctx context.Context, tenantID, jobID uuid.UUID, e *domain.LogEntry, legend jar.APILegend) (*domain.EntryTraceContext, string) {
+	if e.TraceID == "" {
+		return nil, "entry has no trace ID"
+	}
+	entries, err := x.traces.GetTraceEntries(ctx, tenantID.String(), jobID.String(), e.TraceID)
+	if err != nil {
+		slog.Warn("explain: trace lookup failed", "job_id", jobID, "trace_id", e.TraceID, "error", err)
+		return nil, "trace entries could not be read"
+	}
+
+	pos := -1
+	for i := range entries {
+		if entries[i].EntryID == e.EntryID {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		return nil, "entry was not found in its trace"
+	}
+
+	tc := &domain.EntryTraceContext{TraceID: e.TraceID, Position: pos + 1, TraceEntries: len(entries)}
+	for i := pos - 1; i >= 0; i-- {
+		if entries[i].LogType == domain.LogTypeAPI {
+			p := entries[i]
+			ref := &domain.EntryRef{EntryID: p.EntryID, APICode: p.APICode, Timestamp: p.Timestamp, DurationMS: p.DurationMS}
+			if name, ok := legend.Resolve(p.APICode); ok {
+				ref.APIName = name
+			}
+			tc.PrecedingAPI = ref
+			break
+		}
+	}
+
+	if depth, siblings, ok := placeInTree(trace.BuildHierarchy(entries), e.EntryID, 0); ok {
+		tc.Depth = depth
+		for _, s := range siblings {
+			if s.ID != e.EntryID && s.LogType == domain.LogTypeFilter {
+				tc.SiblingFilters++
+			}
+		}
+	}
+	return tc, ""
+}
+
+// placeInTree finds the span of id, returning its depth and the spans at
+// its level under the same parent, itself included.
+func placeInTree(spans []domain.SpanNode, id string, depth int) (int, []domain.SpanNode, bool) {
+	for _, s := range spans {
+		if s.ID == id {
+			return depth, spans, true
+		}
+		if d, siblings, ok := placeInTree(s.Children, id, depth+1); ok {
+			return d, siblings, true
+		}
+	}
+	return 0, nil, false
+}
+
+func (x *Explainer) explainDuration(ctx context.Context, tenantID, jobID uuid.UUID, e *domain.LogEntry) (*domain.DurationPercentile, string) {
+	scope, value, noun := "form", e.Form, "form"
+	if e.LogType == domain.LogTypeSQL && e.SQLTable != "" {
+		scope, value, noun = "sql_table", e.SQLTable, "table"
+	}
+	if value == "" {
+		return nil, "entry has no form or SQL table to compare with"
+	}
+
+	p, err := x.durations.GetDurationPercentile(ctx, tenantID.String(), jobID.String(), e.LogType, scope, value, e.DurationMS)
+	if err != nil {
+		slog.Warn("explain: duration percentile failed", "job_id", jobID, "scope", scope, "error", err)
+		return nil, "duration percentiles could not be computed"
+	}
+	if p.Samples < 2 {
+		return nil, fmt.Sprintf("entry is the only %s entry on that %s", e.LogType, noun)
+	}
+
+	what := e.Operation
+	if what == "" {
+		what = string(e.LogType) + " call"
+	}
+	p.Summary = fmt.Sprintf("this %s on %s is at the %s percentile of durations for that %s in this capture",
+		what, value, ordinal(int(math.Round(p.Percentile))), noun)
+	return p, ""
+}
+
+// ordinal formats n as "1st", "2nd", "98th" and so on.
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
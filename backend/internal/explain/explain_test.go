@@ -0,0 +1,236 @@
+package explain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var (
+	tenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	jobID    = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	t0       = time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+)
+
+func at(ms int) domain.Timestamp {
+	return domain.Timestamp{Time: t0.Add(time.Duration(ms) * time.Millisecond)}
+}
+
+// traceEntries is one API call on thread T1 running three filters, the
+// second of which issues a SELECT on T4381.
+func traceEntries() []domain.LogEntry {
+	return []domain.LogEntry{
+		{EntryID: "e1", TraceID: "tr1", ThreadID: "T1", LogType: domain.LogTypeAPI, APICode: "SE", Form: "HPD:Help Desk", Timestamp: at(0), DurationMS: 100, Success: true},
+		{EntryID: "e2", TraceID: "tr1", ThreadID: "T1", LogType: domain.LogTypeFilter, FilterLevel: 1, FilterName: "HPD:Set Status", Timestamp: at(10), DurationMS: 20, Success: true},
+		{EntryID: "e3", TraceID: "tr1", ThreadID: "T1", LogType: domain.LogTypeFilter, FilterLevel: 1, FilterName: "HPD:Lookup", Timestamp: at(40), DurationMS: 20, Success: true},
+		{EntryID: "e4", TraceID: "tr1", ThreadID: "T1", LogType: domain.LogTypeSQL, SQLTable: "T4381", Operation: "SELECT", APICode: "SE", Timestamp: at(45), DurationMS: 10,
+			ErrorMessage: "ARERR [94] Timeout during database query"},
+		{EntryID: "e5", TraceID: "tr1", ThreadID: "T1", LogType: domain.LogTypeFilter, FilterLevel: 1, FilterName: "HPD:Notify", Timestamp: at(70), DurationMS: 5, Success: true},
+	}
+}
+
+type sources struct {
+	pg *testutil.MockPostgresStore
+	ch *testutil.MockClickHouseStore
+}
+
+func newSources() *sources {
+	return &sources{pg: new(testutil.MockPostgresStore), ch: new(testutil.MockClickHouseStore)}
+}
+
+func (s *sources) explainer() *Explainer {
+	return NewExplainer(s.ch, s.pg, s.ch, s.ch)
+}
+
+func (s *sources) withEntry(id string) domain.LogEntry {
+	for _, e := range traceEntries() {
+		if e.EntryID == id {
+			s.ch.On("GetLogEntry", mock.Anything, tenantID.String(), jobID.String(), id).Return(&e, nil)
+			return e
+		}
+	}
+	panic("no entry " + id)
+}
+
+func TestExplain_Complete(t *testing.T) {
+	s := newSources()
+	s.withEntry("e4")
+	s.pg.On("GetJobAPILegend", mock.Anything, tenantID, jobID).
+		Return([]domain.JARAPIAbbreviation{{Abbreviation: "SE", FullName: "ARSetEntry"}}, nil)
+	s.ch.On("GetTraceEntries", mock.Anything, tenantID.String(), jobID.String(), "tr1").Return(traceEntries(), nil)
+	s.ch.On("GetDurationPercentile", mock.Anything, tenantID.String(), jobID.String(), domain.LogTypeSQL, "sql_table", "T4381", uint32(10)).
+		Return(&domain.DurationPercentile{Scope: "sql_table", Value: "T4381", Percentile: 97.6, Samples: 500}, nil)
+
+	got, err := s.explainer().Explain(context.Background(), tenantID, jobID, "e4")
+	require.NoError(t, err)
+
+	assert.Equal(t, "e4", got.Entry.EntryID)
+	assert.Empty(t, got.Unavailable)
+	assert.Equal(t, &domain.EntryAPIContext{Code: "SE", Name: "ARSetEntry", Source: "jar_parsed"}, got.API)
+
+	require.NotNil(t, got.Error)
+	assert.Equal(t, 94, got.Error.Code)
+	assert.Equal(t, "timeout", got.Error.Category)
+
+	require.NotNil(t, got.Trace)
+	assert.Equal(t, 4, got.Trace.Position)
+	assert.Equal(t, 5, got.Trace.TraceEntries)
+	assert.Equal(t, 2, got.Trace.Depth, "the SELECT runs inside the second filter of the API call")
+	assert.Equal(t, 0, got.Trace.SiblingFilters)
+	require.NotNil(t, got.Trace.PrecedingAPI)
+	assert.Equal(t, "e1", got.Trace.PrecedingAPI.EntryID)
+	assert.Equal(t, "ARSetEntry", got.Trace.PrecedingAPI.APIName)
+
+	require.NotNil(t, got.Duration)
+	assert.Equal(t, "this SELECT on T4381 is at the 98th percentile of durations for that table in this capture", got.Duration.Summary)
+
+	s.pg.AssertExpectations(t)
+	s.ch.AssertExpectations(t)
+}
+
+func TestExplain_FilterSiblings(t *testing.T) {
+	s := newSources()
+	s.withEntry("e3")
+	s.pg.On("GetJobAPILegend", mock.Anything, tenantID, jobID).Return(nil, nil)
+	s.ch.On("GetTraceEntries", mock.Anything, tenantID.String(), jobID.String(), "tr1").Return(traceEntries(), nil)
+
+	got, err := s.explainer().Explain(context.Background(), tenantID, jobID, "e3")
+	require.NoError(t, err)
+	require.NotNil(t, got.Trace)
+	assert.Equal(t, 1, got.Trace.Depth)
+	assert.Equal(t, 2, got.Trace.SiblingFilters, "the other two filters of the API call")
+	assert.Equal(t, "e1", got.Trace.PrecedingAPI.EntryID)
+
+	// Filters have neither API code, error nor form.
+	assert.Nil(t, got.API)
+	assert.Equal(t, "entry has no API code", got.Unavailable[SectionAPI])
+	assert.Nil(t, got.Error)
+	assert.Equal(t, "entry has no error message", got.Unavailable[SectionError])
+	assert.Nil(t, got.Duration)
+	assert.Equal(t, "entry has no form or SQL table to compare with", got.Unavailable[SectionDuration])
+}
+
+func TestExplain_Degradation(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   domain.LogEntry
+		setup   func(s *sources)
+		section string
+		reason  string
+	}{
+		{
+			name:  "unknown API code",
+			entry: domain.LogEntry{EntryID: "x", APICode: "ZZZ"},
+			setup: func(s *sources) {
+				s.pg.On("GetJobAPILegend", mock.Anything, tenantID, jobID).Return(nil, errors.New("db down"))
+			},
+			section: SectionAPI, reason: "API code ZZZ is not in the abbreviation legend",
+		},
+		{
+			name:    "error without AR code",
+			entry:   domain.LogEntry{EntryID: "x", ErrorMessage: "connection reset by peer"},
+			section: SectionError, reason: "error message has no AR error code",
+		},
+		{
+			name:    "AR code outside the taxonomy",
+			entry:   domain.LogEntry{EntryID: "x", ErrorMessage: "ARERR [9999] Something odd"},
+			section: SectionError, reason: "ARERR 9999 is not in the error taxonomy",
+		},
+		{
+			name:    "no trace ID",
+			entry:   domain.LogEntry{EntryID: "x"},
+			section: SectionTrace, reason: "entry has no trace ID",
+		},
+		{
+			name:  "trace unreadable",
+			entry: domain.LogEntry{EntryID: "x", TraceID: "tr1"},
+			setup: func(s *sources) {
+				s.ch.On("GetTraceEntries", mock.Anything, tenantID.String(), jobID.String(), "tr1").Return(nil, errors.New("clickhouse down"))
+			},
+			section: SectionTrace, reason: "trace entries could not be read",
+		},
+		{
+			name:  "entry missing from its trace",
+			entry: domain.LogEntry{EntryID: "x", TraceID: "tr1"},
+			setup: func(s *sources) {
+				s.ch.On("GetTraceEntries", mock.Anything, tenantID.String(), jobID.String(), "tr1").Return(traceEntries(), nil)
+			},
+			section: SectionTrace, reason: "entry was not found in its trace",
+		},
+		{
+			name:  "percentile query fails",
+			entry: domain.LogEntry{EntryID: "x", LogType: domain.LogTypeAPI, Form: "HPD:Help Desk", DurationMS: 300},
+			setup: func(s *sources) {
+				s.ch.On("GetDurationPercentile", mock.Anything, tenantID.String(), jobID.String(), domain.LogTypeAPI, "form", "HPD:Help Desk", uint32(300)).
+					Return(nil, errors.New("clickhouse down"))
+			},
+			section: SectionDuration, reason: "duration percentiles could not be computed",
+		},
+		{
+			name:  "nothing to compare with",
+			entry: domain.LogEntry{EntryID: "x", LogType: domain.LogTypeAPI, Form: "HPD:Help Desk", DurationMS: 300},
+			setup: func(s *sources) {
+				s.ch.On("GetDurationPercentile", mock.Anything, tenantID.String(), jobID.String(), domain.LogTypeAPI, "form", "HPD:Help Desk", uint32(300)).
+					Return(&domain.DurationPercentile{Samples: 1}, nil)
+			},
+			section: SectionDuration, reason: "entry is the only API entry on that form",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSources()
+			s.ch.On("GetLogEntry", mock.Anything, tenantID.String(), jobID.String(), "x").Return(&tt.entry, nil)
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+			s.pg.On("GetJobAPILegend", mock.Anything, tenantID, jobID).Return(nil, nil).Maybe()
+
+			got, err := s.explainer().Explain(context.Background(), tenantID, jobID, "x")
+			require.NoError(t, err, "a missing enrichment is not an error")
+			assert.Equal(t, tt.reason, got.Unavailable[tt.section])
+			s.ch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExplain_EntryMissing(t *testing.T) {
+	s := newSources()
+	s.ch.On("GetLogEntry", mock.Anything, tenantID.String(), jobID.String(), "x").Return(nil, errors.New("clickhouse: get entry: no rows"))
+
+	_, err := s.explainer().Explain(context.Background(), tenantID, jobID, "x")
+	assert.ErrorContains(t, err, "explain: entry x")
+}
+
+func TestARErrorCode(t *testing.T) {
+	for msg, want := range map[string]int{
+		"ARERR [302] Entry does not exist in database": 302,
+		"Authentication failed (ARERR 623)":            623,
+		"ARERR[92]":                                    92,
+	} {
+		code, ok := ARErrorCode(msg)
+		require.True(t, ok, msg)
+		assert.Equal(t, want, code, msg)
+	}
+	_, ok := ARErrorCode("ORA-01017: invalid username/password")
+	assert.False(t, ok)
+
+	e, ok := LookupARError(302)
+	require.True(t, ok)
+	assert.Equal(t, domain.ARErrorExplanation{Code: 302, Category: "data", Summary: "Entry does not exist in database",
+		Hint: "The request ID was deleted or never committed; often a race between workflow and a delete."}, e)
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 98: "98th", 100: "100th"} {
+		assert.Equal(t, want, ordinal(n))
+	}
+}
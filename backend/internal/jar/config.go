@@ -28,6 +28,10 @@ import (
 //	-noesc  -> SkipEsc    (skip escalation log analysis)
 //	-nofltr -> SkipFltr   (skip filter log analysis)
 //	-fts    -> IncludeFTS (include full-text search data)
+//	-of     -> OutputFormat (xml or json report; omitted for text)
+//
+// StrictParse applies to parsing the report and RawTextLimit to storing the
+// entries; neither has a JAR flag.
 func BuildArgs(flags domain.JARFlags, filePath string) []string {
 	var args []string
 
@@ -93,6 +97,12 @@ func BuildArgs(flags domain.JARFlags, filePath string) []string {
 		args = append(args, "-fts")
 	}
 
+	// Report format; the text report needs no flag.
+	switch flags.OutputFormat {
+	case domain.JAROutputXML, domain.JAROutputJSON:
+		args = append(args, "-of", flags.OutputFormat)
+	}
+
 	// The input file path is always the last argument.
 	args = append(args, filePath)
 
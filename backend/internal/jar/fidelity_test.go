@@ -6,6 +6,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -743,4 +745,17 @@ func TestFidelity_Aggregates_FullOutput(t *testing.T) {
 	assert.Greater(t, len(agg.EscByPool.Groups), 0, "Esc by Pool should have groups")
 	require.NotNil(t, agg.EscByPool.GrandTotal, "Esc by Pool should have a grand total")
 	assert.Equal(t, 1, agg.EscByPool.GrandTotal.Total, "Esc by Pool grand total: 1 call")
+
+	// --- Sort metadata from the section headers ---
+	for _, table := range []*domain.JARAggregateTable{
+		agg.APIByForm, agg.APIByClient, agg.APIByClientIP, agg.SQLByTable, agg.EscByForm, agg.EscByPool,
+	} {
+		assert.Equal(t, "AVG execution time", table.SortedBy)
+		assert.Equal(t, domain.SortDescending, table.SortDirection)
+	}
+	assert.Equal(t, "Client IP", agg.APIByClientIP.GroupedBy)
+	assert.Equal(t, "Pool", agg.EscByPool.GroupedBy)
+
+	require.Contains(t, result.Dashboard.TopNSort, "api")
+	assert.Equal(t, domain.TableSort{SortedBy: "execution time", SortDirection: domain.SortDescending}, result.Dashboard.TopNSort["api"])
 }
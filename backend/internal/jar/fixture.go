@@ -0,0 +1,135 @@
+package jar
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultFixtureTableRows is the number of rows of each table a captured
+// fixture keeps when FixtureOptions.MaxTableRows is zero.
+const DefaultFixtureTableRows = 20
+
+// File name suffixes of a parser regression fixture: the report and the
+// JSON snapshot of what the parser made of it. The regression suite runs
+// every fixture under testdata/parser_regressions.
+const (
+	FixtureReportSuffix   = ".txt"
+	FixtureSnapshotSuffix = ".parse.json"
+)
+
+// FixtureOptions tunes CaptureFixture.
+type FixtureOptions struct {
+	Redact RedactOptions
+
+	// MaxTableRows keeps the first rows of each table. Zero means
+	// DefaultFixtureTableRows.
+	MaxTableRows int
+}
+
+func (o FixtureOptions) maxTableRows() int {
+	if o.MaxTableRows <= 0 {
+		return DefaultFixtureTableRows
+	}
+	return o.MaxTableRows
+}
+
+// Fixture is a JAR text report made into a parser regression case.
+type Fixture struct {
+	// Report is the truncated, redacted report.
+	Report string
+	// Snapshot is the indented JSON of the ParseResult the parser makes of
+	// Report in strict mode, or null if it fails to parse it.
+	Snapshot []byte
+	// ParseError is the parser's error, empty if Report parsed.
+	ParseError string
+	// Redactions counts the distinct values replaced per kind.
+	Redactions map[string]int
+}
+
+// CaptureFixture turns a JAR text report into a regression fixture: each
+// table is cut to its first rows, the report is redacted with RedactReport
+// and the result of parsing it is snapshotted.
+func CaptureFixture(report string, opts FixtureOptions) (*Fixture, error) {
+	text := TruncateTables(report, opts.maxTableRows())
+	text, redactions := RedactReport(text, opts.Redact)
+
+	f := &Fixture{Report: text, Redactions: redactions}
+	snapshot, parseErr, err := fixtureSnapshot(text)
+	if err != nil {
+		return nil, err
+	}
+	f.Snapshot = snapshot
+	if parseErr != nil {
+		f.ParseError = parseErr.Error()
+	}
+	return f, nil
+}
+
+// fixtureSnapshot parses a fixture report the way the regression suite does
+// and returns the snapshot of the result. parseErr is the parser's error;
+// err is only set when the result cannot be encoded.
+func fixtureSnapshot(report string) (snapshot []byte, parseErr, err error) {
+	result, parseErr := ParseOutputWithOptions(report, ParseOptions{Strict: true})
+	if parseErr != nil {
+		result = nil
+	}
+	snapshot, err = json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("jar fixture: encode snapshot: %w", err)
+	}
+	return append(snapshot, '\n'), parseErr, nil
+}
+
+// TruncateTables keeps the first maxRows data rows of every table of a JAR
+// text report. Subtotals are kept while no row of the table has been
+// dropped, grand totals always, so that grouped aggregate tables still end
+// as the parser expects. Blank lines left in a row by the cut are merged;
+// lines outside tables are kept as they are.
+func TruncateTables(report string, maxRows int) string {
+	lines := strings.SplitAfter(report, "\n")
+	keep := make([]bool, len(lines))
+	for i := range keep {
+		keep[i] = true
+	}
+	forEachTable(lines, func(start, end int) {
+		sep := -1
+		rows, dropped := 0, false
+		total, keepTotal := false, false
+		lastKept := start - 1
+		for i := start; i < end; i++ {
+			body, _ := splitLineEnd(lines[i])
+			switch {
+			case sep < 0:
+				if i > start && isDashSeparator(body) {
+					sep = i
+				}
+			case strings.TrimSpace(body) == "":
+				keep[i] = lastKept == i-1 || strings.TrimSpace(lines[lastKept]) != ""
+			case isEqualsSeparator(body):
+				keep[i], total, keepTotal = true, true, true
+			case isDashSeparator(body):
+				keep[i], total, keepTotal = !dropped, true, !dropped
+			case total:
+				// The subtotal or grand total under a separator.
+				keep[i], total = keepTotal, false
+			default:
+				rows++
+				keep[i] = rows <= maxRows
+				dropped = dropped || !keep[i]
+			}
+			if keep[i] {
+				lastKept = i
+			}
+		}
+	})
+
+	var b strings.Builder
+	b.Grow(len(report))
+	for i, line := range lines {
+		if keep[i] {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package jar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertSameStructure checks that two decoded JSON documents hold the same
+// objects, arrays, numbers and booleans. Strings may differ, as redacted
+// values do, but not in length.
+func assertSameStructure(t *testing.T, path string, want, got any) {
+	t.Helper()
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		require.True(t, ok, "%s: want an object, got %T", path, got)
+		require.Equal(t, len(w), len(g), "%s: keys", path)
+		for k, v := range w {
+			require.Contains(t, g, k, path)
+			assertSameStructure(t, path+"."+k, v, g[k])
+		}
+	case []any:
+		g, ok := got.([]any)
+		require.True(t, ok, "%s: want an array, got %T", path, got)
+		require.Len(t, g, len(w), path)
+		for i := range w {
+			assertSameStructure(t, fmt.Sprintf("%s[%d]", path, i), w[i], g[i])
+		}
+	case string:
+		g, ok := got.(string)
+		require.True(t, ok, "%s: want a string, got %T", path, got)
+		assert.Len(t, g, len(w), "%s: %q redacted to %q", path, w, g)
+	default:
+		assert.Equal(t, want, got, path)
+	}
+}
+
+func decodeJSON(t *testing.T, data []byte) any {
+	t.Helper()
+	var doc any
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return doc
+}
+
+func TestTruncateTables(t *testing.T) {
+	content, err := os.ReadFile("../../testdata/jar_output_log1.txt")
+	require.NoError(t, err)
+	report := string(content)
+
+	truncated := TruncateTables(report, 3)
+	full, err := ParseOutput(report)
+	require.NoError(t, err)
+	cut, err := ParseOutput(truncated)
+	require.NoError(t, err)
+
+	assert.Len(t, cut.Dashboard.TopAPICalls, 3)
+	assert.Equal(t, full.Dashboard.TopAPICalls[:3], cut.Dashboard.TopAPICalls)
+	assert.Len(t, cut.Dashboard.TopSQL, 3)
+	assert.Len(t, cut.JARGaps.LineGaps, 3)
+	assert.Equal(t, full.Dashboard.GeneralStats, cut.Dashboard.GeneralStats, "the preamble is kept")
+	assert.Equal(t, full.APIAbbreviations, cut.APIAbbreviations, "the legend is no table")
+
+	t.Run("grouped aggregates keep their grand total", func(t *testing.T) {
+		want := full.JARAggregates.APIByForm
+		got := cut.JARAggregates.APIByForm
+		require.NotNil(t, got)
+		rows := 0
+		for _, g := range got.Groups {
+			rows += len(g.Rows)
+		}
+		assert.Equal(t, 3, rows)
+		assert.Equal(t, want.Groups[:2], got.Groups, "whole groups keep their subtotal")
+		assert.Equal(t, want.GrandTotal, got.GrandTotal)
+
+		cut, err := ParseOutput(TruncateTables(report, 4))
+		require.NoError(t, err)
+		groups := cut.JARAggregates.APIByForm.Groups
+		require.Len(t, groups, 3)
+		assert.Len(t, groups[2].Rows, 1)
+		assert.Nil(t, groups[2].Subtotal, "a cut group has none")
+	})
+
+	t.Run("no cut", func(t *testing.T) {
+		assert.Equal(t, report, TruncateTables(report, 1000))
+	})
+}
+
+func TestCaptureFixture(t *testing.T) {
+	content, err := os.ReadFile("../../testdata/jar_output_log1.txt")
+	require.NoError(t, err)
+	report := string(content)
+
+	f, err := CaptureFixture(report, FixtureOptions{Redact: RedactAll})
+	require.NoError(t, err)
+	assert.Empty(t, f.ParseError)
+	for _, kind := range []string{"users", "request_ids", "hosts", "literals"} {
+		assert.Positive(t, f.Redactions[kind], kind)
+	}
+
+	t.Run("no value left behind", func(t *testing.T) {
+		trid := regexp.MustCompile(`[A-Za-z0-9_-]{22}:[0-9]+`)
+		assert.Empty(t, trid.FindAllString(f.Report, -1))
+		for _, secret := range []string{"ITSMAPP1.citc.gov.sa", "10.11.19.2", "/Users/omar", "Remedy Application Service"} {
+			assert.NotContains(t, f.Report, secret)
+		}
+	})
+
+	t.Run("parses to the same structure", func(t *testing.T) {
+		plain, _, err := fixtureSnapshot(TruncateTables(report, DefaultFixtureTableRows))
+		require.NoError(t, err)
+		assertSameStructure(t, "$", decodeJSON(t, plain), decodeJSON(t, f.Snapshot))
+	})
+
+	t.Run("every line keeps its width", func(t *testing.T) {
+		plain := strings.Split(TruncateTables(report, DefaultFixtureTableRows), "\n")
+		redacted := strings.Split(f.Report, "\n")
+		require.Len(t, redacted, len(plain))
+		for i := range plain {
+			assert.Len(t, redacted[i], len(plain[i]), "line %d", i+1)
+		}
+	})
+
+	t.Run("snapshot is what the regression suite sees", func(t *testing.T) {
+		snapshot, parseErr, err := fixtureSnapshot(f.Report)
+		require.NoError(t, err)
+		require.NoError(t, parseErr)
+		assert.Equal(t, string(f.Snapshot), string(snapshot))
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		again, err := CaptureFixture(report, FixtureOptions{Redact: RedactAll})
+		require.NoError(t, err)
+		assert.Equal(t, f.Report, again.Report)
+	})
+}
+
+func TestCaptureFixture_Unparseable(t *testing.T) {
+	f, err := CaptureFixture("\n\n", FixtureOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, f.ParseError, "empty output")
+	assert.Equal(t, "null\n", string(f.Snapshot))
+}
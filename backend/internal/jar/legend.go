@@ -0,0 +1,119 @@
+package jar
+
+import (
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// staticAPINames names the most common AR API call codes. It backs up the
+// legend of JAR versions that do not print one.
+var staticAPINames = map[string]string{
+	"CE":    "ARCreateEntry",
+	"DE":    "ARDeleteEntry",
+	"GE":    "ARGetEntry",
+	"SE":    "ARSetEntry",
+	"ME":    "ARMergeEntry",
+	"GLE":   "ARGetListEntry",
+	"GLEWF": "ARGetListEntryWithFields",
+	"GME":   "ARGetMultipleEntries",
+	"GEB":   "ARGetEntryBLOB",
+	"GLSQL": "ARGetListSQL",
+	"EXP":   "ARExecuteProcess",
+	"GSI":   "ARGetServerInfo",
+	"SSI":   "ARSetServerInfo",
+	"VER":   "ARVerifyUser",
+	"GS":    "ARGetSchema",
+	"GLS":   "ARGetListSchema",
+	"GF":    "ARGetField",
+	"GLF":   "ARGetListField",
+	"GMF":   "ARGetMultipleFields",
+	"GAL":   "ARGetActiveLink",
+	"GLAL":  "ARGetListActiveLink",
+	"GLG":   "ARGetListGroup",
+	"GLU":   "ARGetListUser",
+}
+
+// APILegend resolves API call abbreviations to full API names using the
+// legend of one analysis, falling back to staticAPINames.
+type APILegend map[string]string
+
+// NewAPILegend builds an APILegend from a parsed abbreviation legend. A nil
+// or empty legend resolves from the static names only.
+func NewAPILegend(entries []domain.JARAPIAbbreviation) APILegend {
+	if len(entries) == 0 {
+		return nil
+	}
+	l := make(APILegend, len(entries))
+	for _, e := range entries {
+		l[e.Abbreviation] = e.FullName
+	}
+	return l
+}
+
+// Resolve returns the full API name of code. The begin/end markers of raw
+// log lines ("+GE", "-GE") are ignored. Unknown codes are returned unchanged
+// with ok false. Resolve does not allocate.
+func (l APILegend) Resolve(code string) (name string, ok bool) {
+	key := code
+	if len(key) > 1 && (key[0] == '+' || key[0] == '-') {
+		key = key[1:]
+	}
+	if name, ok := l[key]; ok {
+		return name, true
+	}
+	if name, ok := staticAPINames[key]; ok {
+		return name, true
+	}
+	return code, false
+}
+
+// Mapping returns every abbreviation the legend resolves, static names
+// included, keyed by abbreviation.
+func (l APILegend) Mapping() map[string]string {
+	out := make(map[string]string, len(staticAPINames)+len(l))
+	for k, v := range staticAPINames {
+		out[k] = v
+	}
+	for k, v := range l {
+		out[k] = v
+	}
+	return out
+}
+
+// ExpandTopN sets APIName on top-N entries whose identifier is an API code.
+func (l APILegend) ExpandTopN(entries []domain.TopNEntry) {
+	for i := range entries {
+		if name, ok := l.Resolve(entries[i].Identifier); ok {
+			entries[i].APIName = name
+		}
+	}
+}
+
+// ExpandQueued sets APIName on queued API calls.
+func (l APILegend) ExpandQueued(calls []domain.JARQueuedAPICall) {
+	for i := range calls {
+		if name, ok := l.Resolve(calls[i].API); ok {
+			calls[i].APIName = name
+		}
+	}
+}
+
+// ExpandAggregates sets APIName on the rows of the API aggregate tables,
+// whose operation type is an API code.
+func (l APILegend) ExpandAggregates(agg *domain.JARAggregatesResponse) {
+	if agg == nil {
+		return
+	}
+	for _, table := range []*domain.JARAggregateTable{agg.APIByForm, agg.APIByClient, agg.APIByClientIP} {
+		if table == nil {
+			continue
+		}
+		for g := range table.Groups {
+			rows := table.Groups[g].Rows
+			for i := range rows {
+				if name, ok := l.Resolve(rows[i].OperationType); ok {
+					rows[i].APIName = name
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package jar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestAPILegend_Resolve(t *testing.T) {
+	legend := NewAPILegend([]domain.JARAPIAbbreviation{
+		{Abbreviation: "SE", FullName: "ARSetEntry"},
+		{Abbreviation: "XQ", FullName: "ARCustomQuery"},
+		{Abbreviation: "GE", FullName: "ARGetEntry (legend)"},
+	})
+
+	tests := []struct {
+		name     string
+		legend   APILegend
+		code     string
+		wantName string
+		wantOK   bool
+	}{
+		{"legend entry", legend, "XQ", "ARCustomQuery", true},
+		{"legend overrides static", legend, "GE", "ARGetEntry (legend)", true},
+		{"raw log marker", legend, "+SE", "ARSetEntry", true},
+		{"static fallback with legend", legend, "GLEWF", "ARGetListEntryWithFields", true},
+		{"static fallback without legend", nil, "GLEWF", "ARGetListEntryWithFields", true},
+		{"unknown passes through", legend, "ZZTOP", "ZZTOP", false},
+		{"unknown without legend", nil, "+ZZ", "+ZZ", false},
+		{"empty code", nil, "", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			name, ok := tc.legend.Resolve(tc.code)
+			assert.Equal(t, tc.wantName, name)
+			assert.Equal(t, tc.wantOK, ok)
+		})
+	}
+}
+
+func TestAPILegend_ResolveDoesNotAllocate(t *testing.T) {
+	legend := NewAPILegend([]domain.JARAPIAbbreviation{{Abbreviation: "SE", FullName: "ARSetEntry"}})
+	allocs := testing.AllocsPerRun(100, func() {
+		legend.Resolve("+UNKNOWN")
+		legend.Resolve("GLEWF")
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestAPILegend_Expand(t *testing.T) {
+	legend := NewAPILegend([]domain.JARAPIAbbreviation{{Abbreviation: "XQ", FullName: "ARCustomQuery"}})
+
+	top := []domain.TopNEntry{{Identifier: "XQ"}, {Identifier: "GE"}, {Identifier: "ZZ"}}
+	legend.ExpandTopN(top)
+	assert.Equal(t, "ARCustomQuery", top[0].APIName)
+	assert.Equal(t, "ARGetEntry", top[1].APIName)
+	assert.Empty(t, top[2].APIName)
+
+	agg := &domain.JARAggregatesResponse{
+		APIByForm: &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{
+			{EntityName: "HPD:Help Desk", Rows: []domain.JARAggregateRow{{OperationType: "SE"}, {OperationType: "ZZ"}}},
+		}},
+		SQLByTable: &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{
+			{EntityName: "T1", Rows: []domain.JARAggregateRow{{OperationType: "SE"}}},
+		}},
+	}
+	legend.ExpandAggregates(agg)
+	rows := agg.APIByForm.Groups[0].Rows
+	assert.Equal(t, "ARSetEntry", rows[0].APIName)
+	assert.Empty(t, rows[1].APIName)
+	assert.Empty(t, agg.SQLByTable.Groups[0].Rows[0].APIName, "only API tables are expanded")
+	legend.ExpandAggregates(nil)
+
+	mapping := legend.Mapping()
+	require.Contains(t, mapping, "XQ")
+	assert.Equal(t, "ARGetListEntryWithFields", mapping["GLEWF"])
+}
@@ -1,7 +1,12 @@
 package jar
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,21 +21,43 @@ import (
 // v3 format: === Section Name ===
 // v4 format: ###  SECTION: Name  ##### (major) or ### Subsection Name (sub)
 var (
-	sectionHeaderRe    = regexp.MustCompile(`^={3,}\s*(.+?)\s*={3,}$`)
-	v4MajorSectionRe   = regexp.MustCompile(`^#{3,}\s+SECTION:\s*(.+?)\s*#{3,}`)
-	v4SubsectionRe     = regexp.MustCompile(`^###\s+(.+)$`)
+	sectionHeaderRe     = regexp.MustCompile(`^={3,}\s*(.+?)\s*={3,}$`)
+	v4MajorSectionRe    = regexp.MustCompile(`^#{3,}\s+SECTION:\s*(.+?)\s*#{3,}`)
+	v4SubsectionRe      = regexp.MustCompile(`^###\s+(.+)$`)
 	dashSeparatorLineRe = regexp.MustCompile(`^[-\s]+$`)
-	separatorRe        = regexp.MustCompile(`^-{3,}$`)
+	separatorRe         = regexp.MustCompile(`^-{3,}$`)
 )
 
-// Common timestamp layouts produced by the JAR.
-var timestampLayouts = []string{
-	"Mon Jan 02 2006 15:04:05.000",
-	"Mon Jan 02 2006 15:04:05",
-	"2006-01-02 15:04:05.000",
-	"2006-01-02 15:04:05",
-	"2006/01/02 15:04:05",
-	"01/02/2006 15:04:05",
+// DefaultMaxSectionRows is the per-section line cap applied when
+// ParseOptions.MaxSectionRows is zero.
+const DefaultMaxSectionRows = 500_000
+
+// maxReportLineBytes bounds a single report line read by ParseReader. Top-N
+// SQL sections can carry very long statements.
+const maxReportLineBytes = 16 * 1024 * 1024
+
+// ParseOptions tunes ParseReader.
+type ParseOptions struct {
+	// MaxSectionRows caps the lines buffered for one section. Lines past
+	// the cap are dropped and a warning is logged. Zero means
+	// DefaultMaxSectionRows.
+	MaxSectionRows int
+
+	// Strict collects ParseResult.Diagnostics: the sections no parser
+	// recognized, the table rows that could not be read and the coverage
+	// of each table. It does not change what is parsed.
+	Strict bool
+
+	// Profile, when set, forces the report format the report is parsed
+	// as instead of selecting it from the report.
+	Profile *ParserProfile
+}
+
+func (o ParseOptions) maxSectionRows() int {
+	if o.MaxSectionRows <= 0 {
+		return DefaultMaxSectionRows
+	}
+	return o.MaxSectionRows
 }
 
 // ParseOutput parses the plain-text report produced by ARLogAnalyzer.jar
@@ -40,365 +67,416 @@ var timestampLayouts = []string{
 // lines. Each section contains either key-value statistics or tabular
 // top-N data. This parser is intentionally lenient: unrecognized lines
 // and sections are silently skipped so that minor JAR version differences
-// do not cause hard failures.
+// do not cause hard failures. ParseOptions.Strict reports what was skipped.
+//
+// Sections are dispatched through the ParserProfile of the report format,
+// selected from the analyzer version in the report's banner or, without
+// one, from the style of its section headers. A version no profile was
+// tested with is parsed with the nearest profile and a warning in
+// ParseResult.Warnings.
 //
 // The returned ParseResult.Dashboard is always populated. Section pointers
 // (Aggregates, Exceptions, Gaps, ThreadStats, Filters) are nil until
 // enhanced analysis populates them in later processing stages.
+//
+// ParseOutput is a thin wrapper for reports already in memory; reports read
+// from a file or pipe should be streamed through ParseReader.
 func ParseOutput(output string) (*domain.ParseResult, error) {
+	return ParseOutputWithOptions(output, ParseOptions{})
+}
+
+// ParseOutputWithOptions is ParseOutput with explicit options. Lines are
+// sliced out of output rather than copied, and as with ParseReader only one
+// section is held in memory besides output itself.
+func ParseOutputWithOptions(output string, opts ParseOptions) (*domain.ParseResult, error) {
 	if strings.TrimSpace(output) == "" {
 		return nil, fmt.Errorf("jar parser: empty output")
 	}
+	result, _, err := parseSections(&stringLines{s: output}, opts)
+	return result, err
+}
+
+// ParseReader parses a JAR report from r like ParseOutput, but streams it:
+// each section is parsed as soon as its last line has been read, so memory
+// use is bounded by the largest section (see ParseOptions.MaxSectionRows)
+// rather than by the size of the report.
+func ParseReader(r io.Reader, opts ParseOptions) (*domain.ParseResult, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxReportLineBytes)
+	sc.Split(scanRawLines)
+
+	result, nonBlank, err := parseSections(&readerLines{sc: sc}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !nonBlank {
+		return nil, fmt.Errorf("jar parser: empty output")
+	}
+	return result, nil
+}
 
+// parseSections splits the lines from src into sections and parses each one
+// as it completes. nonBlank reports whether src had any non-blank line.
+func parseSections(src lineSource, opts ParseOptions) (result *domain.ParseResult, nonBlank bool, err error) {
 	data := &domain.DashboardData{
 		Distribution: make(map[string]map[string]int),
 	}
+	result = &domain.ParseResult{Dashboard: data}
 
-	result := &domain.ParseResult{Dashboard: data}
+	var diag *diagnostics
+	if opts.Strict {
+		diag = newDiagnostics()
+		result.Diagnostics = diag.d
+	}
 
-	sections := splitSections(output)
+	// The profile is selected once the first section is complete: the
+	// banner is in the preamble and the first header closes it.
+	sniffer := &profileSniffer{lineSource: src}
+	profile := opts.Profile
+	nonBlank, err = streamSections(sniffer, opts.maxSectionRows(), func(name string, body []string) {
+		if profile == nil {
+			profile = selectReportProfile(result, sniffer)
+		}
+		recognized := parseSection(result, profile, name, body)
+		if diag != nil {
+			diag.observe(profile, name, body, recognized)
+		}
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if profile == nil {
+		profile = selectReportProfile(result, sniffer)
+	}
+	result.Profile = profile.Name
 
-	for name, body := range sections {
-		normalized := strings.ToLower(strings.TrimSpace(name))
+	finishParseResult(result)
+	return result, nonBlank, nil
+}
 
-		switch {
-		// Preamble contains general stats in v4 format.
-		case name == "_preamble":
-			parseGeneralStatistics(body, &data.GeneralStats)
-
-		// v3: "General Statistics"
-		case strings.Contains(normalized, "general statistic"):
-			parseGeneralStatistics(body, &data.GeneralStats)
-
-		// --- GAP ANALYSIS ---
-		case strings.Contains(normalized, "longest line gap"):
-			entries := parseGapEntries(body)
-			if len(entries) > 0 {
-				if result.JARGaps == nil {
-					result.JARGaps = &domain.JARGapsResponse{Source: "jar_parsed"}
-				}
-				result.JARGaps.LineGaps = entries
-			}
-		case strings.Contains(normalized, "longest thread gap"):
-			entries := parseGapEntries(body)
-			if len(entries) > 0 {
-				if result.JARGaps == nil {
-					result.JARGaps = &domain.JARGapsResponse{Source: "jar_parsed"}
-				}
-				result.JARGaps.ThreadGaps = entries
-			}
+// selectReportProfile selects the profile of the report sniffer read,
+// recording its analyzer version and any warning in result.
+func selectReportProfile(result *domain.ParseResult, sniffer *profileSniffer) *ParserProfile {
+	profile, warning := selectProfile(sniffer.version, sniffer.firstHeader)
+	if sniffer.version != nil {
+		result.JARVersion = sniffer.version.String()
+	}
+	if warning != "" {
+		slog.Warn("jar parser: "+warning, "jar_version", result.JARVersion, "profile", profile.Name)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	return profile
+}
 
-		// --- ABBREVIATION LEGEND ---
-		case strings.Contains(normalized, "abbreviation legend"):
-			result.APIAbbreviations = parseAPIAbbreviationLegend(body)
+// finishParseResult derives the parts of result that depend on more than
+// one section, once every section has been parsed.
+func finishParseResult(result *domain.ParseResult) {
+	// Copy TopFilters to JARFilters.LongestRunning if available.
+	if len(result.Dashboard.TopFilters) > 0 && result.JARFilters != nil {
+		result.JARFilters.LongestRunning = result.Dashboard.TopFilters
+	}
+	// Recover the names the filter sections truncated from the wider
+	// columns of the others.
+	ResolveTruncatedNames(result.JARFilters, nil, reportForms(result))
+	result.Sections = sectionPresence(result)
+	result.Sections.Warnings = append(result.Sections.Warnings, result.Warnings...)
+}
 
-		// --- API TOP-N ---
-		case strings.Contains(normalized, "top") && strings.Contains(normalized, "api"):
-			data.TopAPICalls = parseTopNSection(body)
-		case strings.Contains(normalized, "longest") && strings.Contains(normalized, "running") && strings.Contains(normalized, "api"):
-			data.TopAPICalls = parseTopNSection(body)
+// parseSection dispatches one section body to the parser the profile's
+// matching table gives its name and reports whether any rule recognized
+// it. Section parsers only read body while they run; the slice is reused
+// for the next section and must not be retained.
+func parseSection(result *domain.ParseResult, profile *ParserProfile, name string, body []string) bool {
+	s := section{
+		profile:    profile,
+		name:       name,
+		normalized: strings.ToLower(strings.TrimSpace(name)),
+		body:       body,
+	}
+	for _, rule := range profile.Sections {
+		if rule.match(s) {
+			rule.parse(result, s)
+			return true
+		}
+	}
+	return false
+}
 
-		// --- QUEUED API CALLS ---
-		case strings.Contains(normalized, "queued") && strings.Contains(normalized, "api"):
-			if !sectionContainsNoData(body) {
-				result.QueuedAPICalls = parseTopNSection(body)
-			}
+// Section header metadata patterns, e.g. "API CALL AGGREGATES grouped by
+// Form sorted by descending AVG execution time".
+var (
+	sortClauseRe    = regexp.MustCompile(`(?i)\s*\bsorted\s+by\b\s*(.*)$`)
+	groupClauseRe   = regexp.MustCompile(`(?i)\bby\s+(.+)$`)
+	leadingDirRe    = regexp.MustCompile(`(?i)^(ascending|descending|asc|desc)\b\s*`)
+	trailingDirRe   = regexp.MustCompile(`(?i)\s*[(,]?\s*\b(ascending|descending|asc|desc)\s*\)?$`)
+	headerTrailerRe = regexp.MustCompile(`[\s#=:-]+$`)
+)
 
-		// --- API AGGREGATES ---
-		case strings.Contains(normalized, "api call aggregates") && strings.Contains(normalized, "by form"):
-			table := parseGroupedAggregateTable(body)
-			if table != nil {
-				if result.JARAggregates == nil {
-					result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
-				}
-				if idx := strings.Index(normalized, "sorted by"); idx >= 0 {
-					table.SortedBy = strings.TrimSpace(name[strings.Index(strings.ToLower(name), "sorted by")+10:])
-				}
-				result.JARAggregates.APIByForm = table
-			}
-		case strings.Contains(normalized, "api call aggregates") && strings.Contains(normalized, "by client ip"):
-			table := parseGroupedAggregateTable(body)
-			if table != nil {
-				if result.JARAggregates == nil {
-					result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
-				}
-				if idx := strings.Index(normalized, "sorted by"); idx >= 0 {
-					table.SortedBy = strings.TrimSpace(name[strings.Index(strings.ToLower(name), "sorted by")+10:])
-				}
-				result.JARAggregates.APIByClientIP = table
-			}
-		case strings.Contains(normalized, "api call aggregates") && strings.Contains(normalized, "by client"):
-			table := parseGroupedAggregateTable(body)
-			if table != nil {
-				if result.JARAggregates == nil {
-					result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
-				}
-				if idx := strings.Index(normalized, "sorted by"); idx >= 0 {
-					table.SortedBy = strings.TrimSpace(name[strings.Index(strings.ToLower(name), "sorted by")+10:])
-				}
-				result.JARAggregates.APIByClient = table
-			}
+// parseSectionMeta extracts the grouping entity, the sort key and the sort
+// direction from a section header. Parts the header does not state are
+// returned empty.
+func parseSectionMeta(name string) (groupedBy, sortedBy string, dir domain.SortDirection) {
+	name = headerTrailerRe.ReplaceAllString(strings.TrimSpace(name), "")
 
-		// --- API THREAD STATISTICS ---
-		case strings.Contains(normalized, "api thread statistics"):
-			entries := parseThreadStatsTable(body)
-			if len(entries) > 0 {
-				if result.JARThreadStats == nil {
-					result.JARThreadStats = &domain.JARThreadStatsResponse{Source: "jar_parsed"}
-				}
-				result.JARThreadStats.APIThreads = entries
-			}
+	head := name
+	if loc := sortClauseRe.FindStringSubmatchIndex(name); loc != nil {
+		head = name[:loc[0]]
+		sortedBy = name[loc[2]:loc[3]]
 
-		// --- API ERRORS ---
-		case strings.Contains(normalized, "errored out"):
-			entries := parseAPIErrors(body)
-			if len(entries) > 0 {
-				if result.JARExceptions == nil {
-					result.JARExceptions = &domain.JARExceptionsResponse{Source: "jar_parsed"}
-				}
-				result.JARExceptions.APIErrors = entries
-			}
+		if m := leadingDirRe.FindStringSubmatch(sortedBy); m != nil {
+			dir = sortDirection(m[1])
+			sortedBy = sortedBy[len(m[0]):]
+		} else if m := trailingDirRe.FindStringSubmatch(sortedBy); m != nil {
+			dir = sortDirection(m[1])
+			sortedBy = sortedBy[:len(sortedBy)-len(m[0])]
+		}
+		sortedBy = headerTrailerRe.ReplaceAllString(sortedBy, "")
+	}
 
-		// --- API EXCEPTION REPORT ---
-		case strings.Contains(normalized, "api exception report"):
-			entries := parseExceptionReport(body)
-			if len(entries) > 0 {
-				if result.JARExceptions == nil {
-					result.JARExceptions = &domain.JARExceptionsResponse{Source: "jar_parsed"}
-				}
-				result.JARExceptions.APIExceptions = entries
-			}
+	if m := groupClauseRe.FindStringSubmatch(head); m != nil {
+		groupedBy = headerTrailerRe.ReplaceAllString(m[1], "")
+	}
+	return groupedBy, sortedBy, dir
+}
 
-		// --- SQL TOP-N ---
-		case strings.Contains(normalized, "top") && strings.Contains(normalized, "sql"):
-			data.TopSQL = parseTopNSection(body)
-		case strings.Contains(normalized, "longest") && strings.Contains(normalized, "running") && strings.Contains(normalized, "sql"):
-			data.TopSQL = parseTopNSection(body)
-
-		// --- SQL AGGREGATES ---
-		case strings.Contains(normalized, "sql call aggregates") && strings.Contains(normalized, "by table"):
-			table := parseGroupedAggregateTable(body)
-			if table != nil {
-				if result.JARAggregates == nil {
-					result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
-				}
-				if idx := strings.Index(normalized, "sorted by"); idx >= 0 {
-					table.SortedBy = strings.TrimSpace(name[strings.Index(strings.ToLower(name), "sorted by")+10:])
-				}
-				result.JARAggregates.SQLByTable = table
-			}
+func sortDirection(word string) domain.SortDirection {
+	if strings.HasPrefix(strings.ToLower(word), "asc") {
+		return domain.SortAscending
+	}
+	return domain.SortDescending
+}
 
-		// --- SQL THREAD STATISTICS ---
-		case strings.Contains(normalized, "sql thread statistics"):
-			entries := parseThreadStatsTable(body)
-			if len(entries) > 0 {
-				if result.JARThreadStats == nil {
-					result.JARThreadStats = &domain.JARThreadStatsResponse{Source: "jar_parsed"}
-				}
-				result.JARThreadStats.SQLThreads = entries
-			}
+// parseAggregateSection parses an aggregate table and labels it with the
+// grouping and ordering stated in its header.
+func parseAggregateSection(name string, body []string) *domain.JARAggregateTable {
+	table := parseGroupedAggregateTable(body)
+	if table == nil {
+		return nil
+	}
+	groupedBy, sortedBy, dir := parseSectionMeta(name)
+	if groupedBy != "" {
+		table.GroupedBy = groupedBy
+	}
+	table.SortedBy = sortedBy
+	table.SortDirection = dir
+	return table
+}
 
-		// --- SQL EXCEPTION REPORT ---
-		case strings.Contains(normalized, "sql exception report"):
-			entries := parseExceptionReport(body)
-			if len(entries) > 0 {
-				if result.JARExceptions == nil {
-					result.JARExceptions = &domain.JARExceptionsResponse{Source: "jar_parsed"}
-				}
-				result.JARExceptions.SQLExceptions = entries
-			}
+// jarAggregates returns result.JARAggregates, creating it on first use.
+func jarAggregates(result *domain.ParseResult) *domain.JARAggregatesResponse {
+	if result.JARAggregates == nil {
+		result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
+	}
+	return result.JARAggregates
+}
 
-		// --- ESCALATION TOP-N ---
-		case strings.Contains(normalized, "top") && strings.Contains(normalized, "escalation"):
-			data.TopEscalations = parseTopNSection(body)
-		case strings.Contains(normalized, "longest") && strings.Contains(normalized, "running") && (strings.Contains(normalized, "escl") || strings.Contains(normalized, "escalation")):
-			data.TopEscalations = parseTopNSection(body)
-
-		// --- ESCALATION AGGREGATES ---
-		case strings.Contains(normalized, "escalation call aggregates") && strings.Contains(normalized, "by form"):
-			table := parseGroupedAggregateTable(body)
-			if table != nil {
-				if result.JARAggregates == nil {
-					result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
-				}
-				if idx := strings.Index(normalized, "sorted by"); idx >= 0 {
-					table.SortedBy = strings.TrimSpace(name[strings.Index(strings.ToLower(name), "sorted by")+10:])
-				}
-				result.JARAggregates.EscByForm = table
-			}
-		case strings.Contains(normalized, "escalation call aggregates") && strings.Contains(normalized, "by pool"):
-			table := parseGroupedAggregateTable(body)
-			if table != nil {
-				if result.JARAggregates == nil {
-					result.JARAggregates = &domain.JARAggregatesResponse{Source: "jar_parsed"}
-				}
-				if idx := strings.Index(normalized, "sorted by"); idx >= 0 {
-					table.SortedBy = strings.TrimSpace(name[strings.Index(strings.ToLower(name), "sorted by")+10:])
-				}
-				result.JARAggregates.EscByPool = table
-			}
+// jarExceptions returns result.JARExceptions, creating it on first use.
+func jarExceptions(result *domain.ParseResult) *domain.JARExceptionsResponse {
+	if result.JARExceptions == nil {
+		result.JARExceptions = &domain.JARExceptionsResponse{Source: "jar_parsed"}
+	}
+	return result.JARExceptions
+}
 
-		// --- FILTER TOP-N (longest running) ---
-		case strings.Contains(normalized, "top") && strings.Contains(normalized, "filter"):
-			data.TopFilters = parseTopNSection(body)
-		case strings.Contains(normalized, "longest") && strings.Contains(normalized, "running") && strings.Contains(normalized, "fltr"):
-			data.TopFilters = parseTopNSection(body)
-
-		// --- FILTER: MOST EXECUTED PER TRANSACTION (must match before "most executed fltr") ---
-		case strings.Contains(normalized, "most executed fltr per transaction"):
-			entries := parseFilterExecutedPerTxn(body)
-			if len(entries) > 0 {
-				if result.JARFilters == nil {
-					result.JARFilters = &domain.JARFilterComplexityResponse{Source: "jar_parsed"}
-				}
-				result.JARFilters.ExecutedPerTxn = entries
-			}
+// jarGaps returns result.JARGaps, creating it on first use.
+func jarGaps(result *domain.ParseResult) *domain.JARGapsResponse {
+	if result.JARGaps == nil {
+		result.JARGaps = &domain.JARGapsResponse{Source: "jar_parsed"}
+	}
+	return result.JARGaps
+}
 
-		// --- FILTER: MOST EXECUTED ---
-		case strings.Contains(normalized, "most executed fltr"):
-			entries := parseMostExecutedFilters(body)
-			if len(entries) > 0 {
-				if result.JARFilters == nil {
-					result.JARFilters = &domain.JARFilterComplexityResponse{Source: "jar_parsed"}
-				}
-				result.JARFilters.MostExecuted = entries
-			}
+// jarFilters returns result.JARFilters, creating it on first use.
+func jarFilters(result *domain.ParseResult) *domain.JARFilterComplexityResponse {
+	if result.JARFilters == nil {
+		result.JARFilters = &domain.JARFilterComplexityResponse{Source: "jar_parsed"}
+	}
+	return result.JARFilters
+}
 
-		// --- FILTER: MOST FILTERS PER TRANSACTION ---
-		case strings.Contains(normalized, "most filters per transaction"):
-			entries := parseFilterPerTransaction(body)
-			if len(entries) > 0 {
-				if result.JARFilters == nil {
-					result.JARFilters = &domain.JARFilterComplexityResponse{Source: "jar_parsed"}
-				}
-				result.JARFilters.PerTransaction = entries
-			}
+// jarThreadStats returns result.JARThreadStats, creating it on first use.
+func jarThreadStats(result *domain.ParseResult) *domain.JARThreadStatsResponse {
+	if result.JARThreadStats == nil {
+		result.JARThreadStats = &domain.JARThreadStatsResponse{Source: "jar_parsed"}
+	}
+	return result.JARThreadStats
+}
 
-		// --- FILTER: MOST FILTER LEVELS ---
-		case strings.Contains(normalized, "most filter levels"):
-			entries := parseFilterLevels(body)
-			if len(entries) > 0 {
-				if result.JARFilters == nil {
-					result.JARFilters = &domain.JARFilterComplexityResponse{Source: "jar_parsed"}
-				}
-				result.JARFilters.FilterLevels = entries
-			}
+// topNSort returns the ordering of a top-N section. Top-N headers rarely
+// carry a sort clause; "longest" and "top" tables are ranked by descending
+// execution time, queued tables by descending queue time.
+func topNSort(name string) domain.TableSort {
+	_, sortedBy, dir := parseSectionMeta(name)
+	if sortedBy != "" {
+		return domain.TableSort{SortedBy: sortedBy, SortDirection: dir}
+	}
 
-		// --- LOGGING ACTIVITY ---
-		case strings.Contains(normalized, "logging activity"):
-			activities := parseLoggingActivity(body)
-			if len(activities) > 0 {
-				result.LoggingActivities = activities
-			}
+	normalized := strings.ToLower(name)
+	switch {
+	case strings.Contains(normalized, "queued"):
+		return domain.TableSort{SortedBy: "queue time", SortDirection: domain.SortDescending}
+	case strings.Contains(normalized, "longest"), strings.Contains(normalized, "top"):
+		return domain.TableSort{SortedBy: "execution time", SortDirection: domain.SortDescending}
+	}
+	return domain.TableSort{}
+}
 
-		// --- FILE INFORMATION / INPUT FILENAMES ---
-		case strings.Contains(normalized, "input filename") || strings.Contains(normalized, "file information"):
-			files := parseFileMetadata(body)
-			if len(files) > 0 {
-				result.FileMetadataList = files
-			}
+// setTopNSort records the ordering of the top-N table stored under key.
+func setTopNSort(data *domain.DashboardData, key, name string) {
+	if data.TopNSort == nil {
+		data.TopNSort = make(map[string]domain.TableSort)
+	}
+	data.TopNSort[key] = topNSort(name)
+}
 
-		// --- GENERIC FALLBACKS (v3 compatibility) ---
-		case strings.Contains(normalized, "thread") && !strings.Contains(normalized, "gap") && !strings.Contains(normalized, "api thread") && !strings.Contains(normalized, "sql thread"):
-			parseDistribution(body, data, "threads")
-		case (strings.Contains(normalized, "exception") || strings.Contains(normalized, "error")) &&
-			!strings.Contains(normalized, "errored out") &&
-			!strings.Contains(normalized, "api exception") &&
-			!strings.Contains(normalized, "sql exception"):
-			parseDistribution(body, data, "errors")
-		case strings.Contains(normalized, "user") && !strings.Contains(normalized, "count"):
-			parseDistribution(body, data, "users")
-		case strings.Contains(normalized, "form") && !strings.Contains(normalized, "count") && !strings.Contains(normalized, "longest") && !strings.Contains(normalized, "aggregates"):
-			parseDistribution(body, data, "forms")
-		}
+// preambleSection names the lines before the first section header.
+const preambleSection = "_preamble"
+
+// lineSource yields report lines without their trailing newline.
+type lineSource interface {
+	next() (string, bool)
+	err() error
+}
+
+// stringLines iterates the lines of an in-memory report without copying.
+// It yields the same lines as strings.Split(s, "\n").
+type stringLines struct {
+	s    string
+	done bool
+}
+
+func (l *stringLines) next() (string, bool) {
+	if l.done {
+		return "", false
 	}
+	i := strings.IndexByte(l.s, '\n')
+	if i < 0 {
+		l.done = true
+		return l.s, true
+	}
+	line := l.s[:i]
+	l.s = l.s[i+1:]
+	return line, true
+}
 
-	// Copy TopFilters to JARFilters.LongestRunning if available.
-	if len(data.TopFilters) > 0 && result.JARFilters != nil {
-		result.JARFilters.LongestRunning = data.TopFilters
+func (l *stringLines) err() error { return nil }
+
+// readerLines adapts a bufio.Scanner split with scanRawLines.
+type readerLines struct {
+	sc *bufio.Scanner
+}
+
+func (l *readerLines) next() (string, bool) {
+	if !l.sc.Scan() {
+		return "", false
 	}
+	return l.sc.Text(), true
+}
 
-	return result, nil
+func (l *readerLines) err() error {
+	if err := l.sc.Err(); err != nil {
+		return fmt.Errorf("jar parser: read output: %w", err)
+	}
+	return nil
 }
 
-// splitSections splits the JAR output into named sections.
-//
-// Supports two formats:
+// scanRawLines is a bufio.SplitFunc that splits on '\n' only. Unlike
+// bufio.ScanLines it keeps a trailing '\r' and yields a final empty line
+// after a trailing newline, so a streamed report produces exactly the lines
+// of strings.Split(report, "\n").
+func scanRawLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if !atEOF {
+		return 0, nil, nil
+	}
+	if len(data) == 0 {
+		return 0, []byte{}, bufio.ErrFinalToken
+	}
+	return len(data), data, bufio.ErrFinalToken
+}
+
+// sectionName returns the section a header line opens, if line is one.
+// The header patterns of every registered profile are tried, since a
+// report is split into sections before its profile is known:
 //   - v3: "=== Section Name ===" headers
 //   - v4: "###  SECTION: Name  ###..." major headers and "### Subsection" sub-headers
-//
-// Lines before the first section header are collected into a special
-// "_preamble" section so that v4 general statistics (which appear before
-// any section) are not lost.
-func splitSections(output string) map[string][]string {
-	sections := make(map[string][]string)
-	lines := strings.Split(output, "\n")
-
-	currentSection := ""
-	var currentBody []string
-	hasSectionHeader := false
+func sectionName(line string) (string, bool) {
+	for _, re := range headerPatterns {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
 
-	for _, line := range lines {
-		// v3 format: === Section Name ===
-		if m := sectionHeaderRe.FindStringSubmatch(line); m != nil {
-			if currentSection != "" || !hasSectionHeader {
-				if currentSection != "" {
-					sections[currentSection] = currentBody
-				} else if len(currentBody) > 0 {
-					sections["_preamble"] = currentBody
-				}
+// streamSections groups the lines from src into named sections and calls
+// emit for each section once its last line has been read, in report order.
+//
+// Lines before the first section header are emitted as preambleSection so
+// that v4 general statistics (which appear before any section) are not
+// lost. Only the current section is held in memory, capped at maxRows
+// lines; the rest of an oversized section is dropped with a warning. The
+// body slice is reused between sections, so emit must not retain it.
+func streamSections(src lineSource, maxRows int, emit func(name string, body []string)) (nonBlank bool, err error) {
+	current := ""
+	var body []string
+	dropped := 0
+
+	flush := func() {
+		name := current
+		if name == "" {
+			if len(body) == 0 {
+				return
 			}
-			currentSection = m[1]
-			currentBody = nil
-			hasSectionHeader = true
-			continue
+			name = preambleSection
 		}
+		if dropped > 0 {
+			slog.Warn("jar parser: section truncated",
+				"section", name, "max_rows", maxRows, "dropped_rows", dropped)
+		}
+		emit(name, body)
+	}
 
-		// v4 format: ###  SECTION: Name  #####...
-		if m := v4MajorSectionRe.FindStringSubmatch(line); m != nil {
-			if currentSection != "" {
-				sections[currentSection] = currentBody
-			} else if len(currentBody) > 0 {
-				sections["_preamble"] = currentBody
-			}
-			currentSection = m[1]
-			currentBody = nil
-			hasSectionHeader = true
-			continue
+	for {
+		line, ok := src.next()
+		if !ok {
+			break
+		}
+		if !nonBlank && strings.TrimSpace(line) != "" {
+			nonBlank = true
 		}
 
-		// v4 subsection: ### Subsection Name
-		if m := v4SubsectionRe.FindStringSubmatch(line); m != nil {
-			// Don't treat lines inside a non-### context as subsections
-			// (e.g., markdown in other content). Only split on subsections
-			// when we've already seen at least one ### or === header,
-			// or we're still in the preamble.
-			if currentSection != "" {
-				sections[currentSection] = currentBody
-			} else if len(currentBody) > 0 {
-				sections["_preamble"] = currentBody
-			}
-			currentSection = m[1]
-			currentBody = nil
-			hasSectionHeader = true
+		if name, ok := sectionName(line); ok {
+			flush()
+			current = name
+			body = body[:0]
+			dropped = 0
 			continue
 		}
 
-		if hasSectionHeader || currentSection != "" {
-			currentBody = append(currentBody, line)
-		} else {
-			// Accumulate preamble lines before any header is found.
-			currentBody = append(currentBody, line)
+		if len(body) >= maxRows {
+			dropped++
+			continue
 		}
+		body = append(body, line)
 	}
-
-	// Save the last section.
-	if currentSection != "" {
-		sections[currentSection] = currentBody
-	} else if len(currentBody) > 0 && !hasSectionHeader {
-		sections["_preamble"] = currentBody
+	if err := src.err(); err != nil {
+		return nonBlank, err
 	}
 
+	flush()
+	return nonBlank, nil
+}
+
+// splitSections splits an in-memory report into named sections keyed by
+// section name. A repeated section name keeps its last body.
+func splitSections(output string) map[string][]string {
+	sections := make(map[string][]string)
+	_, _ = streamSections(&stringLines{s: output}, math.MaxInt, func(name string, body []string) {
+		sections[name] = append([]string(nil), body...)
+	})
 	return sections
 }
 
@@ -418,6 +496,13 @@ func splitSections(output string) map[string][]string {
 //	Log Start:              Mon Feb 03 2026 10:00:00.123
 //	Log End:                Mon Feb 03 2026 18:30:45.678
 //	Log Duration:           8h 30m 45s
+//
+// v4 output also reports the threads configured per queue, including
+// private queues whose names hold a colon:
+//
+//	     Fast Thread Count: 4
+//	Prv:390680 Thread Count: 2
+//	    Total Thread Count: 31
 func parseGeneralStatistics(lines []string, stats *domain.GeneralStatistics) {
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -425,6 +510,14 @@ func parseGeneralStatistics(lines []string, stats *domain.GeneralStatistics) {
 			continue
 		}
 
+		if queue, count, ok := parseThreadCount(line); ok {
+			if stats.ThreadCounts == nil {
+				stats.ThreadCounts = make(map[string]int)
+			}
+			stats.ThreadCounts[queue] = count
+			continue
+		}
+
 		key, value, ok := splitKeyValue(line)
 		if !ok {
 			continue
@@ -475,11 +568,27 @@ func parseGeneralStatistics(lines []string, stats *domain.GeneralStatistics) {
 		// v3: "Log Duration", v4: "Elapsed Time"
 		case strings.Contains(keyLower, "log duration") || strings.Contains(keyLower, "elapsed"):
 			stats.LogDuration = value
+			stats.LogDurationMS = domain.DurationMS(parseDurationToMS(value))
 		}
 	}
 }
 
-// parseTopNSection parses a tabular top-N section into TopNEntry slices.
+// parseThreadCount parses a "<queue> Thread Count: <n>" preamble line. The
+// total over all queues is not a queue and is skipped.
+func parseThreadCount(line string) (queue string, count int, ok bool) {
+	const suffix = " thread count"
+	key, count, ok := splitKeyValueNumeric(line)
+	if !ok || !strings.HasSuffix(strings.ToLower(key), suffix) {
+		return "", 0, false
+	}
+	queue = strings.TrimSpace(key[:len(key)-len(suffix)])
+	if queue == "" || strings.EqualFold(queue, "total") {
+		return "", 0, false
+	}
+	return queue, count, true
+}
+
+// parseTopNTable parses a tabular top-N section into TopNEntry slices.
 //
 // The JAR can produce several table formats. This parser handles two
 // common layouts:
@@ -494,34 +603,43 @@ func parseGeneralStatistics(lines []string, stats *domain.GeneralStatistics) {
 //
 //	Rank  Line#  Timestamp               Thread  Queue  Identifier  Form              User      Duration(ms)  Status
 //	1     4523   Mon Feb 03 2026 10:...  T024    Fast   GE          HPD:Help Desk     Demo      5000          Success
-func parseTopNSection(lines []string) []domain.TopNEntry {
-	var entries []domain.TopNEntry
-
-	// Detect the table format by scanning lines.
-	isPipeFormat := false
-	isFixedWidth := false
-	for _, line := range lines {
+//
+// The layout is detected from the first line that gives it away. A body
+// that looks like both the pipe and the fixed-width layout is read as
+// expected, the layout of the report format, when one is given.
+func parseTopNTable(lines []string, expected TableFormat) []domain.TopNEntry {
+	firstPipe, firstFixed := -1, -1
+	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 3 {
-			isPipeFormat = true
-			break
-		}
-		if isDashSeparator(line) && strings.Contains(line, " ") {
-			isFixedWidth = true
-			break
+		switch {
+		case strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 3:
+			if firstPipe < 0 {
+				firstPipe = i
+			}
+		case isDashSeparator(line) && strings.Contains(line, " "):
+			if firstFixed < 0 {
+				firstFixed = i
+			}
 		}
 	}
 
+	var detected TableFormat
 	switch {
-	case isPipeFormat:
-		entries = parsePipeTable(lines)
-	case isFixedWidth:
-		entries = parseFixedWidthTable(lines)
-	default:
-		entries = parseWhitespaceTable(lines)
+	case firstPipe >= 0 && firstFixed >= 0 && expected != 0:
+		detected = expected
+	case firstPipe >= 0 && (firstFixed < 0 || firstPipe < firstFixed):
+		detected = TableFormatPipe
+	case firstFixed >= 0:
+		detected = TableFormatFixedWidth
 	}
 
-	return entries
+	switch detected {
+	case TableFormatPipe:
+		return parsePipeTable(lines)
+	case TableFormatFixedWidth:
+		return parseFixedWidthTable(lines)
+	}
+	return parseWhitespaceTable(lines)
 }
 
 // parsePipeTable parses a pipe-delimited table.
@@ -639,7 +757,7 @@ func parseTopNLine(line string) (domain.TopNEntry, bool) {
 		for j := i + 1; j < len(fields) && j <= i+5; j++ {
 			combined := strings.Join(fields[i:j+1], " ")
 			if ts, ok := tryParseTimestamp(combined); ok {
-				entry.Timestamp = ts
+				entry.Timestamp = domain.NewTimestamp(ts)
 				idx = j + 1
 				break
 			}
@@ -649,7 +767,7 @@ func parseTopNLine(line string) (domain.TopNEntry, bool) {
 		}
 		// Try the single field.
 		if ts, ok := tryParseTimestamp(candidate); ok {
-			entry.Timestamp = ts
+			entry.Timestamp = domain.NewTimestamp(ts)
 			idx = i + 1
 			break
 		}
@@ -892,6 +1010,8 @@ func mapFixedWidthToEntry(headers, values []string) domain.TopNEntry {
 			entry.Details += "last_line=" + v
 		case h == "trid":
 			entry.TraceID = v
+		case h == "rpc" || h == "rpc id":
+			entry.RPCID = v
 		case h == "queue":
 			entry.Queue = v
 		case h == "api":
@@ -1097,9 +1217,9 @@ func parseIntSafe(s string) int64 {
 
 // parseTimestampSafe attempts to parse a timestamp string using known
 // layouts. Returns the zero time on failure.
-func parseTimestampSafe(s string) time.Time {
+func parseTimestampSafe(s string) domain.Timestamp {
 	ts, _ := tryParseTimestamp(s)
-	return ts
+	return domain.NewTimestamp(ts)
 }
 
 // tryParseTimestamp attempts to parse a timestamp string against all
@@ -1195,6 +1315,7 @@ func parseGapEntries(lines []string) []domain.JARGapEntry {
 		entry := domain.JARGapEntry{}
 		if gapCol >= 0 && gapCol < len(values) {
 			entry.GapDuration = parseFloatSafe(values[gapCol])
+			entry.GapDurationMS = domain.DurationMSFromSeconds(entry.GapDuration)
 		}
 		if lineCol >= 0 && lineCol < len(values) {
 			entry.LineNumber = int(parseIntSafe(values[lineCol]))
@@ -1215,6 +1336,73 @@ func parseGapEntries(lines []string) []domain.JARGapEntry {
 	return entries
 }
 
+// parseQueuedAPICalls parses the fixed-width "LONGEST QUEUED INDIVIDUAL API
+// CALLS" table, keeping the queue wait ("Q Time") apart from the run time.
+// The Start Time column is when the call was dispatched to a thread; the
+// wait started Q Time before it.
+func parseQueuedAPICalls(lines []string) []domain.JARQueuedAPICall {
+	sepIdx := -1
+	for i, line := range lines {
+		if isDashSeparator(line) {
+			sepIdx = i
+			break
+		}
+	}
+	if sepIdx < 1 {
+		return nil
+	}
+
+	boundaries := extractColumnBoundaries(lines[sepIdx])
+	headers := extractColumnValues(lines[sepIdx-1], boundaries)
+	cols := make(map[string]int, len(headers))
+	for i, h := range headers {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	column := func(values []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := cols[name]; ok && i < len(values) {
+				return values[i]
+			}
+		}
+		return ""
+	}
+
+	var calls []domain.JARQueuedAPICall
+	for i := sepIdx + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "No ") {
+			continue
+		}
+		if isDashSeparator(lines[i]) || isEqualsSeparator(lines[i]) || strings.HasPrefix(trimmed, "###") {
+			break
+		}
+
+		values := extractColumnValues(lines[i], boundaries)
+		call := domain.JARQueuedAPICall{
+			TraceID: column(values, "trid"),
+			RPCID:   column(values, "rpc", "rpc id"),
+			Queue:   column(values, "queue"),
+			API:     column(values, "api"),
+			Form:    column(values, "form"),
+			User:    column(values, "user"),
+			WaitMS:  parseFloatSecondsToMS(column(values, "q time", "queue time")),
+			RunMS:   parseFloatSecondsToMS(column(values, "run time")),
+			Success: strings.EqualFold(column(values, "success"), "true"),
+		}
+		call.LineNumber, _ = strconv.Atoi(column(values, "first line#", "line#"))
+		call.TotalMS = call.WaitMS + call.RunMS
+		call.Dispatch = parseTimestampSafe(column(values, "start time", "date/time"))
+		if !call.Dispatch.IsZero() {
+			call.WaitStart = domain.NewTimestamp(call.Dispatch.Add(-time.Duration(call.WaitMS) * time.Millisecond))
+		}
+		if call.API != "" || call.TotalMS > 0 || call.LineNumber > 0 {
+			call.Rank = len(calls) + 1
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
 // parseGroupedAggregateTable parses a grouped aggregate table (API/SQL/Escalation aggregates).
 // Handles the two-pass grouped pattern: entity → operation rows → subtotal (------) → grand total (======).
 func parseGroupedAggregateTable(lines []string) *domain.JARAggregateTable {
@@ -1288,6 +1476,10 @@ func parseGroupedAggregateTable(lines []string) *domain.JARAggregateTable {
 			AvgTime:       parseFloatSafe(getVal(values, avgTimeCol)),
 			SumTime:       parseFloatSafe(getVal(values, sumTimeCol)),
 		}
+		row.MinTimeMS = domain.DurationMSFromSeconds(row.MinTime)
+		row.MaxTimeMS = domain.DurationMSFromSeconds(row.MaxTime)
+		row.AvgTimeMS = domain.DurationMSFromSeconds(row.AvgTime)
+		row.SumTimeMS = domain.DurationMSFromSeconds(row.SumTime)
 		if totalCol >= 0 {
 			row.Total = int(parseIntSafe(getVal(values, totalCol)))
 		} else if countCol >= 0 {
@@ -1445,9 +1637,11 @@ func parseThreadStatsTable(lines []string) []domain.JARThreadStat {
 		}
 		if qTimeCol >= 0 && qTimeCol < len(values) {
 			stat.QTime = parseFloatSafe(values[qTimeCol])
+			stat.QTimeMS = domain.DurationMSFromSeconds(stat.QTime)
 		}
 		if totalTimeCol >= 0 && totalTimeCol < len(values) {
 			stat.TotalTime = parseFloatSafe(values[totalTimeCol])
+			stat.TotalTimeMS = domain.DurationMSFromSeconds(stat.TotalTime)
 		}
 		if busyCol >= 0 && busyCol < len(values) {
 			s := strings.TrimSuffix(strings.TrimSpace(values[busyCol]), "%")
@@ -1921,30 +2115,46 @@ func parseAPIAbbreviationLegend(lines []string) []domain.JARAPIAbbreviation {
 	return entries
 }
 
+// durationPartRegex matches one component of a JAR duration such as "8h",
+// "30m", "12.877s" or "500ms".
+var durationPartRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(ms|d|h|m|s)`)
+
 // parseDurationToMS converts a human-readable duration string (e.g., "8h 30m 45s") to milliseconds.
+// Bare numbers such as "10.162" are seconds and "02:30:00" is read as hh:mm:ss.
 func parseDurationToMS(s string) int64 {
 	s = strings.TrimSpace(s)
 	if s == "" {
 		return 0
 	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return int64(math.Round(secs * 1000))
+	}
+	if parts := strings.Split(s, ":"); len(parts) == 3 {
+		h, errH := strconv.ParseFloat(parts[0], 64)
+		m, errM := strconv.ParseFloat(parts[1], 64)
+		sec, errS := strconv.ParseFloat(parts[2], 64)
+		if errH == nil && errM == nil && errS == nil {
+			return int64(math.Round((h*3600 + m*60 + sec) * 1000))
+		}
+	}
 
-	var totalMS int64
-	re := regexp.MustCompile(`(\d+)\s*(h|m|s|ms)`)
-	matches := re.FindAllStringSubmatch(s, -1)
-	for _, m := range matches {
-		val := parseIntSafe(m[1])
+	var total float64
+	for _, m := range durationPartRegex.FindAllStringSubmatch(s, -1) {
+		val, _ := strconv.ParseFloat(m[1], 64)
 		switch m[2] {
+		case "d":
+			total += val * 86400000
 		case "h":
-			totalMS += val * 3600000
+			total += val * 3600000
 		case "m":
-			totalMS += val * 60000
+			total += val * 60000
 		case "s":
-			totalMS += val * 1000
+			total += val * 1000
 		case "ms":
-			totalMS += val
+			total += val
 		}
 	}
-	return totalMS
+	return int64(math.Round(total))
 }
 
 // parseLoggingActivity parses the "Logging Activity" section.
@@ -0,0 +1,43 @@
+package jar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parserRegressionDir holds the reports that once broke the parser, each
+// next to the snapshot of what the parser should make of it. Fixtures are
+// captured from analyses with POST /api/v1/admin/analyses/{id}/capture-fixture
+// and dropped in as they are; a snapshot is edited, or re-recorded with
+// UPDATE_GOLDEN=1, once the parser handles the report as intended.
+const parserRegressionDir = "../../testdata/parser_regressions"
+
+func TestParserRegressions(t *testing.T) {
+	reports, err := filepath.Glob(filepath.Join(parserRegressionDir, "*"+FixtureReportSuffix))
+	require.NoError(t, err)
+	require.NotEmpty(t, reports, "no fixtures in %s", parserRegressionDir)
+
+	for _, path := range reports {
+		name := strings.TrimSuffix(filepath.Base(path), FixtureReportSuffix)
+		t.Run(name, func(t *testing.T) {
+			report, err := os.ReadFile(path)
+			require.NoError(t, err)
+			got, _, err := fixtureSnapshot(string(report))
+			require.NoError(t, err)
+
+			snapshotPath := strings.TrimSuffix(path, FixtureReportSuffix) + FixtureSnapshotSuffix
+			if os.Getenv("UPDATE_GOLDEN") != "" {
+				require.NoError(t, os.WriteFile(snapshotPath, got, 0o644))
+				return
+			}
+			want, err := os.ReadFile(snapshotPath)
+			require.NoError(t, err, "snapshot missing; run with UPDATE_GOLDEN=1 to record it")
+			assert.JSONEq(t, string(want), string(got), "parse result differs from %s", snapshotPath)
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package jar
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Streaming parser tests: ParseReader must produce exactly what the former
+// split-everything-then-parse implementation produced, while holding only
+// one section in memory.
+// ---------------------------------------------------------------------------
+
+// legacySplitSections is the pre-streaming section splitter, kept verbatim
+// as the reference for the equivalence tests.
+func legacySplitSections(output string) map[string][]string {
+	sections := make(map[string][]string)
+	lines := strings.Split(output, "\n")
+
+	currentSection := ""
+	var currentBody []string
+	hasSectionHeader := false
+
+	for _, line := range lines {
+		// v3 format: === Section Name ===
+		if m := sectionHeaderRe.FindStringSubmatch(line); m != nil {
+			if currentSection != "" || !hasSectionHeader {
+				if currentSection != "" {
+					sections[currentSection] = currentBody
+				} else if len(currentBody) > 0 {
+					sections["_preamble"] = currentBody
+				}
+			}
+			currentSection = m[1]
+			currentBody = nil
+			hasSectionHeader = true
+			continue
+		}
+
+		// v4 format: ###  SECTION: Name  #####...
+		if m := v4MajorSectionRe.FindStringSubmatch(line); m != nil {
+			if currentSection != "" {
+				sections[currentSection] = currentBody
+			} else if len(currentBody) > 0 {
+				sections["_preamble"] = currentBody
+			}
+			currentSection = m[1]
+			currentBody = nil
+			hasSectionHeader = true
+			continue
+		}
+
+		// v4 subsection: ### Subsection Name
+		if m := v4SubsectionRe.FindStringSubmatch(line); m != nil {
+			// Don't treat lines inside a non-### context as subsections
+			// (e.g., markdown in other content). Only split on subsections
+			// when we've already seen at least one ### or === header,
+			// or we're still in the preamble.
+			if currentSection != "" {
+				sections[currentSection] = currentBody
+			} else if len(currentBody) > 0 {
+				sections["_preamble"] = currentBody
+			}
+			currentSection = m[1]
+			currentBody = nil
+			hasSectionHeader = true
+			continue
+		}
+
+		if hasSectionHeader || currentSection != "" {
+			currentBody = append(currentBody, line)
+		} else {
+			// Accumulate preamble lines before any header is found.
+			currentBody = append(currentBody, line)
+		}
+	}
+
+	// Save the last section.
+	if currentSection != "" {
+		sections[currentSection] = currentBody
+	} else if len(currentBody) > 0 && !hasSectionHeader {
+		sections["_preamble"] = currentBody
+	}
+
+	return sections
+}
+
+// legacyParseOutput is the pre-streaming implementation: the whole report is
+// split into a section map first and each section parsed afterwards.
+func legacyParseOutput(output string) (*domain.ParseResult, error) {
+	if strings.TrimSpace(output) == "" {
+		return nil, fmt.Errorf("jar parser: empty output")
+	}
+	data := &domain.DashboardData{Distribution: make(map[string]map[string]int)}
+	result := &domain.ParseResult{Dashboard: data}
+	sniffer := &profileSniffer{lineSource: &stringLines{s: output}}
+	for _, ok := sniffer.next(); ok; _, ok = sniffer.next() {
+	}
+	profile := selectReportProfile(result, sniffer)
+	result.Profile = profile.Name
+	for name, body := range legacySplitSections(output) {
+		parseSection(result, profile, name, body)
+	}
+	finishParseResult(result)
+	return result, nil
+}
+
+// parserFixtures returns every JAR report fixture in testdata plus inline
+// reports covering formatting edge cases.
+func parserFixtures(t testing.TB) map[string]string {
+	fixtures := map[string]string{
+		"v3 sections": "=== General Statistics ===\nTotal Lines Processed: 1000\nAPI Calls: 400\n\n" +
+			"=== Top API Calls ===\n| Rank | Line# | Timestamp | Thread | Queue | Identifier | Form | User | Duration (ms) | Status |\n" +
+			"|------|-------|-----------|--------|-------|------------|------|------|---------------|--------|\n" +
+			"| 1 | 10 | 2026-02-03 10:00:00 | T1 | Fast | GE | HPD:Help Desk | Demo | 5000 | Success |\n\n" +
+			"=== Thread Distribution ===\nT1: 10\nT2: 5\n",
+		"v4 preamble and subsections": "Total Lines Processed: 1000\nAPI Calls: 10\n\n" +
+			"###  SECTION: API  ######\n### 50 LONGEST RUNNING INDIVIDUAL API CALLS\n\nnothing\n",
+		"crlf line endings":    "=== General Statistics ===\r\nAPI Calls: 12\r\n=== User Distribution ===\r\nDemo: 3\r\n",
+		"no trailing newline":  "=== User Distribution ===\nDemo: 3\nAllen: 2",
+		"empty sections":       "=== Top API Calls ===\n=== Form Distribution ===\n\n",
+		"preamble only":        "Total Lines Processed: 42\nUnique Users: 2\n",
+		"repeated blank lines": "\n\n\n=== General Statistics ===\n\n\nSQL Operations: 7\n\n\n",
+	}
+
+	paths, err := filepath.Glob("../../testdata/jar_output_*.txt")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		fixtures[filepath.Base(path)] = string(content)
+	}
+	return fixtures
+}
+
+func TestParseReader_MatchesLegacyParser(t *testing.T) {
+	for name, report := range parserFixtures(t) {
+		t.Run(name, func(t *testing.T) {
+			want, err := legacyParseOutput(report)
+			require.NoError(t, err)
+
+			assert.Equal(t, legacySplitSections(report), splitSections(report), "splitSections")
+
+			got, err := ParseOutput(report)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "ParseOutput")
+
+			got, err = ParseReader(strings.NewReader(report), ParseOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "ParseReader")
+		})
+	}
+}
+
+func TestParseReader_EmptyInput(t *testing.T) {
+	for _, input := range []string{"", "  \n\t\n"} {
+		_, err := ParseReader(strings.NewReader(input), ParseOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "empty output")
+	}
+}
+
+func TestParseReader_LongLines(t *testing.T) {
+	long := strings.Repeat("x", 256*1024)
+	report := "=== Form Distribution ===\n" + long + ": 4\nHPD:Help Desk: 2\n"
+
+	result, err := ParseReader(strings.NewReader(report), ParseOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Dashboard.Distribution["forms"][long])
+	assert.Equal(t, 2, result.Dashboard.Distribution["forms"]["HPD:Help Desk"])
+}
+
+func TestParseReader_SectionRowCap(t *testing.T) {
+	report := "=== User Distribution ===\nu1: 1\nu2: 2\nu3: 3\nu4: 4\n" +
+		"=== Form Distribution ===\nf1: 1\n"
+
+	result, err := ParseReader(strings.NewReader(report), ParseOptions{MaxSectionRows: 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"u1": 1, "u2": 2}, result.Dashboard.Distribution["users"])
+	assert.Equal(t, map[string]int{"f1": 1}, result.Dashboard.Distribution["forms"], "the cap applies per section")
+}
+
+func TestStreamSections_ReusesBody(t *testing.T) {
+	report := "pre\n=== A ===\na1\na2\n=== B ===\nb1\n"
+
+	var names []string
+	var sizes []int
+	_, err := streamSections(&stringLines{s: report}, DefaultMaxSectionRows, func(name string, body []string) {
+		names = append(names, name)
+		sizes = append(sizes, len(body))
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{preambleSection, "A", "B"}, names, "sections are emitted in report order")
+	assert.Equal(t, []int{1, 2, 2}, sizes, "B keeps the trailing empty line")
+}
+
+func TestLineSources_MatchStringsSplit(t *testing.T) {
+	for _, input := range []string{"", "a", "a\n", "a\r\nb", "\n\n", "a\nb\n\nc"} {
+		want := strings.Split(input, "\n")
+
+		var got []string
+		src := &stringLines{s: input}
+		for line, ok := src.next(); ok; line, ok = src.next() {
+			got = append(got, line)
+		}
+		assert.Equal(t, want, got, "stringLines %q", input)
+
+		got = nil
+		sc := bufio.NewScanner(strings.NewReader(input))
+		sc.Split(scanRawLines)
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		require.NoError(t, sc.Err())
+		assert.Equal(t, want, got, "scanRawLines %q", input)
+	}
+}
+
+// syntheticAggregateHeaders are the aggregate sections that grow with the
+// capture length.
+var syntheticAggregateHeaders = []string{
+	"### API CALL AGGREGATES grouped by Form sorted by descending AVG execution time",
+	"### API CALL AGGREGATES grouped by Client sorted by descending AVG execution time",
+	"### API CALL AGGREGATES grouped by Client IP sorted by descending AVG execution time",
+	"### SQL CALL AGGREGATES grouped by Table sorted by descending AVG execution time",
+	"### Escalation CALL AGGREGATES grouped by Form sorted by descending AVG execution time",
+	"### Escalation CALL AGGREGATES grouped by Pool sorted by descending AVG execution time",
+}
+
+// syntheticReport builds a v4 report of about lines lines, spread over the
+// aggregate sections as produced by month-long captures.
+func syntheticReport(lines int) string {
+	var b strings.Builder
+	b.WriteString("Total Lines Processed: 123456789\nAPI Calls: 98765432\n\n")
+	b.WriteString("###  SECTION: API  ##########\n")
+	groups := lines / 4 / len(syntheticAggregateHeaders)
+	for _, header := range syntheticAggregateHeaders {
+		b.WriteString(header + "\n\n")
+		b.WriteString("Group                                                        API            OK   Fail  Total     MIN Time MIN Line     MAX Time MAX Line     AVG Time     SUM Time\n")
+		b.WriteString("------------------------------------------------------------ ---------- ------ ------ ------ ------------ -------- ------------ -------- ------------ ------------\n")
+		for i := 0; i < groups; i++ {
+			fmt.Fprintf(&b, "%-60s %-10s %6d %6d %6d %12.3f %8d %12.3f %8d %12.3f %12.3f\n",
+				fmt.Sprintf("Group%07d", i), "GLEWF", 3, 0, 3, 0.002, i, 0.120, i+1, 0.041, 0.123)
+			b.WriteString("                                                                       ------ ------ ------                                                          ------------\n")
+			b.WriteString("                                                                            3             3                                                                 0.123\n")
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("### 50 LONGEST RUNNING INDIVIDUAL API CALLS\n\nnone\n")
+	return b.String()
+}
+
+// TestStreamSections_AllocatesFarLessThanLegacySplit checks the point of
+// streaming: splitting no longer allocates in proportion to the report.
+func TestStreamSections_AllocatesFarLessThanLegacySplit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation comparison runs benchmarks")
+	}
+	report := syntheticReport(200_000)
+
+	legacy := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			legacySplitSections(report)
+		}
+	})
+	streamed := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = streamSections(&stringLines{s: report}, DefaultMaxSectionRows, func(string, []string) {})
+		}
+	})
+
+	t.Logf("legacy %d B/op, streamed %d B/op", legacy.AllocedBytesPerOp(), streamed.AllocedBytesPerOp())
+	assert.Less(t, streamed.AllocedBytesPerOp()*4, legacy.AllocedBytesPerOp(),
+		"streaming should allocate under a quarter of the legacy splitter")
+}
+
+func BenchmarkParseOutput_Legacy(b *testing.B) {
+	report := syntheticReport(1_000_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := legacyParseOutput(report); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseOutput(b *testing.B) {
+	report := syntheticReport(1_000_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseOutput(report); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseReader(b *testing.B) {
+	report := syntheticReport(1_000_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseReader(strings.NewReader(report), ParseOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSplitSections_Legacy(b *testing.B) {
+	report := syntheticReport(1_000_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacySplitSections(report)
+	}
+}
+
+func BenchmarkStreamSections(b *testing.B) {
+	report := syntheticReport(1_000_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := streamSections(&stringLines{s: report}, DefaultMaxSectionRows, func(string, []string) {}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package jar
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -185,6 +190,36 @@ Escalation Thread Count: 2
 	assert.False(t, data.GeneralStats.LogStart.IsZero(), "Start Time should be parsed")
 	assert.False(t, data.GeneralStats.LogEnd.IsZero(), "End Time should be parsed")
 	assert.Equal(t, "10.162", data.GeneralStats.LogDuration)
+	assert.Equal(t, domain.DurationMS(10162), data.GeneralStats.LogDurationMS)
+	assert.Equal(t, map[string]int{"AssignEng": 1, "Escalation": 2, "Fast": 4, "Init": 2, "List": 20}, data.GeneralStats.ThreadCounts)
+}
+
+// TestV4_PreambleThreadCounts verifies that the per-queue thread counts are
+// read, including private queues whose names hold a colon, and that the
+// total and the other counts are not mistaken for queues.
+func TestV4_PreambleThreadCounts(t *testing.T) {
+	output := `
+              API Count: 251
+      Fast Thread Count: 4
+      List Thread Count: 20
+Prv:390680 Thread Count: 2
+Escalation Thread Count: 2
+     Total Thread Count: 28
+    API Exception Count: 3
+              ESC Count: 6260
+`
+	result, err := ParseOutput(output)
+	require.NoError(t, err)
+	stats := result.Dashboard.GeneralStats
+
+	assert.Equal(t, map[string]int{"Fast": 4, "List": 20, "Prv:390680": 2, "Escalation": 2}, stats.ThreadCounts)
+	assert.Equal(t, int64(251), stats.APICount)
+	assert.Equal(t, int64(6260), stats.EscCount)
+
+	// v3 output has no thread counts.
+	result, err = ParseOutput("=== General Statistics ===\nAPI Calls: 400\n")
+	require.NoError(t, err)
+	assert.Nil(t, result.Dashboard.GeneralStats.ThreadCounts)
 }
 
 // ---------------------------------------------------------------------------
@@ -708,6 +743,7 @@ AP:Signature                                                CE              1
 	require.NotNil(t, table.GrandTotal, "grand total should be present")
 	assert.Equal(t, 11, table.GrandTotal.Total)
 	assert.InDelta(t, 0.220, table.GrandTotal.SumTime, 0.001)
+	assert.Equal(t, domain.DurationMS(220), table.GrandTotal.SumTimeMS)
 }
 
 // ---------------------------------------------------------------------------
@@ -811,6 +847,7 @@ func TestParseGapEntries_LineGaps(t *testing.T) {
 
 	// First entry
 	assert.InDelta(t, 0.265, entries[0].GapDuration, 0.001)
+	assert.Equal(t, domain.DurationMS(265), entries[0].GapDurationMS)
 	assert.Equal(t, 0, entries[0].LineNumber)
 	assert.Equal(t, "oKNmA5MvSwOxCzBulz9-zQ:0003436", entries[0].TraceID)
 	assert.Contains(t, entries[0].Details, "BEGIN TRANSACTION")
@@ -846,6 +883,45 @@ func TestParseGapEntries_ThreadGaps(t *testing.T) {
 	assert.InDelta(t, 0.024, entries[1].GapDuration, 0.001)
 }
 
+// ---------------------------------------------------------------------------
+// parseQueuedAPICalls — queue wait split from run time
+// ---------------------------------------------------------------------------
+
+func TestParseQueuedAPICalls(t *testing.T) {
+	report, err := os.ReadFile(filepath.Join(parserRegressionDir, "jar_output_queued.txt"))
+	require.NoError(t, err)
+	result, err := ParseOutput(string(report))
+	require.NoError(t, err)
+
+	require.Len(t, result.QueuedAPICalls, 4, "the generic top-N rows are still populated")
+	require.Len(t, result.JARQueuedAPICalls, 4)
+
+	first := result.JARQueuedAPICalls[0]
+	assert.Equal(t, 1, first.Rank)
+	assert.Equal(t, 20415, first.LineNumber)
+	assert.Equal(t, "Prv:390620", first.Queue)
+	assert.Equal(t, "GLEWF", first.API)
+	assert.Equal(t, "HPD:Help Desk", first.Form)
+	assert.Equal(t, 6873, first.WaitMS)
+	assert.Equal(t, 412, first.RunMS)
+	assert.Equal(t, 7285, first.TotalMS)
+	assert.Greater(t, first.WaitMS, first.RunMS, "slow because queued")
+	assert.Equal(t, time.Date(2026, 3, 3, 9, 15, 42, 118_000_000, time.UTC), first.Dispatch.UTC())
+	assert.Equal(t, time.Date(2026, 3, 3, 9, 15, 35, 245_000_000, time.UTC), first.WaitStart.UTC())
+	assert.True(t, first.Success)
+
+	third := result.JARQueuedAPICalls[2]
+	assert.Equal(t, "SE", third.API)
+	assert.Less(t, third.WaitMS, third.RunMS, "slow because executing")
+	assert.Equal(t, 11320, third.TotalMS)
+
+	assert.False(t, result.JARQueuedAPICalls[3].Success)
+}
+
+func TestParseQueuedAPICalls_NoData(t *testing.T) {
+	assert.Nil(t, parseQueuedAPICalls([]string{"", "No Queued API's", ""}))
+}
+
 // ---------------------------------------------------------------------------
 // T031: parseThreadStatsTable — API (with QCount/QTime columns)
 // ---------------------------------------------------------------------------
@@ -869,6 +945,7 @@ Prv:390680 0000000356 Mon Nov 24 2025 14:47:03.376 Mon Nov 24 2025 14:47:03.937
 	assert.Equal(t, "0000000365", entries[0].ThreadID)
 	assert.Equal(t, 5, entries[0].Count)
 	assert.InDelta(t, 0.034, entries[0].TotalTime, 0.001)
+	assert.Equal(t, domain.DurationMS(34), entries[0].TotalTimeMS)
 	assert.InDelta(t, 0.33, entries[0].BusyPct, 0.01)
 
 	// Queue propagation: 0000000316 should inherit Queue="Fast" from 0000000314.
@@ -992,3 +1069,64 @@ func TestParseExceptionReport_SQL(t *testing.T) {
 	assert.Contains(t, e.Message, "WARNING")
 	assert.Contains(t, e.SQLStatement, "SELECT T4381")
 }
+
+// ---------------------------------------------------------------------------
+// parseSectionMeta — grouping entity and sort clause from section headers
+// ---------------------------------------------------------------------------
+
+func TestParseSectionMeta(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		groupedBy string
+		sortedBy  string
+		dir       domain.SortDirection
+	}{
+		// v4 aggregate headers, verbatim from testdata/jar_output_log1.txt.
+		{"v4 api by form", "API CALL AGGREGATES grouped by Form sorted by descending AVG execution time", "Form", "AVG execution time", domain.SortDescending},
+		{"v4 api by client", "API CALL AGGREGATES grouped by Client sorted by descending AVG execution time", "Client", "AVG execution time", domain.SortDescending},
+		{"v4 api by client ip", "API CALL AGGREGATES grouped by Client IP sorted by descending AVG execution time", "Client IP", "AVG execution time", domain.SortDescending},
+		{"v4 sql by table", "SQL CALL AGGREGATES grouped by Table sorted by descending AVG execution time", "Table", "AVG execution time", domain.SortDescending},
+		{"v4 esc by form", "Escalation CALL AGGREGATES grouped by Form sorted by descending AVG execution time", "Form", "AVG execution time", domain.SortDescending},
+		{"v4 esc by pool", "Escalation CALL AGGREGATES grouped by Pool sorted by descending AVG execution time", "Pool", "AVG execution time", domain.SortDescending},
+
+		// Casing and trailing header decoration.
+		{"upper case clause", "API CALL AGGREGATES GROUPED BY FORM SORTED BY ASCENDING SUM TIME", "FORM", "SUM TIME", domain.SortAscending},
+		{"trailing hashes", "SQL CALL AGGREGATES grouped by Table sorted by descending AVG execution time  #########", "Table", "AVG execution time", domain.SortDescending},
+		{"trailing direction", "API Call Aggregates by Form sorted by AVG Time (desc)", "Form", "AVG Time", domain.SortDescending},
+		{"sort without direction", "API Call Aggregates by Form sorted by Total", "Form", "Total", ""},
+
+		// v3 headers and headers without a sort clause.
+		{"v3 aggregates", "API Call Aggregates by Form", "Form", "", ""},
+		{"v4 top-n", "50 LONGEST RUNNING INDIVIDUAL API CALLS", "", "", ""},
+		{"v4 filters", "50 MOST FILTERS PER TRANSACTION ", "", "", ""},
+		{"v3 top-n", "Top API Calls", "", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			groupedBy, sortedBy, dir := parseSectionMeta(tc.header)
+			assert.Equal(t, tc.groupedBy, groupedBy)
+			assert.Equal(t, tc.sortedBy, sortedBy)
+			assert.Equal(t, tc.dir, dir)
+		})
+	}
+}
+
+func TestTopNSort(t *testing.T) {
+	tests := []struct {
+		header string
+		want   domain.TableSort
+	}{
+		{"50 LONGEST RUNNING INDIVIDUAL SQL CALLS", domain.TableSort{SortedBy: "execution time", SortDirection: domain.SortDescending}},
+		{"50 LONGEST QUEUED INDIVIDUAL API CALLS", domain.TableSort{SortedBy: "queue time", SortDirection: domain.SortDescending}},
+		{"Top Escalations sorted by ascending start time", domain.TableSort{SortedBy: "start time", SortDirection: domain.SortAscending}},
+		{"Filters", domain.TableSort{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.header, func(t *testing.T) {
+			assert.Equal(t, tc.want, topNSort(tc.header))
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package jar
+
+import "github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+
+// sectionPresence derives which log types a report covers from the
+// preamble counts and from the sections that produced rows. A log type
+// counts as present when either says so; reconciling a disagreement is
+// left to the ingestion, which sees the actual entries.
+func sectionPresence(r *domain.ParseResult) *domain.SectionPresence {
+	stats := r.Dashboard.GeneralStats
+	var apiRows, sqlRows, filterRows, escRows bool
+
+	apiRows = len(r.Dashboard.TopAPICalls) > 0 || len(r.QueuedAPICalls) > 0
+	sqlRows = len(r.Dashboard.TopSQL) > 0
+	filterRows = len(r.Dashboard.TopFilters) > 0
+	escRows = len(r.Dashboard.TopEscalations) > 0
+
+	if a := r.JARAggregates; a != nil {
+		apiRows = apiRows || hasGroups(a.APIByForm) || hasGroups(a.APIByClient) || hasGroups(a.APIByClientIP)
+		sqlRows = sqlRows || hasGroups(a.SQLByTable)
+		escRows = escRows || hasGroups(a.EscByForm) || hasGroups(a.EscByPool)
+	}
+	if e := r.JARExceptions; e != nil {
+		apiRows = apiRows || len(e.APIErrors) > 0 || len(e.APIExceptions) > 0
+		sqlRows = sqlRows || len(e.SQLExceptions) > 0
+	}
+	if f := r.JARFilters; f != nil {
+		filterRows = filterRows || len(f.LongestRunning) > 0 || len(f.MostExecuted) > 0 ||
+			len(f.PerTransaction) > 0 || len(f.ExecutedPerTxn) > 0 || len(f.FilterLevels) > 0
+	}
+
+	return &domain.SectionPresence{
+		API:        domain.LogTypePresence{Present: stats.APICount > 0 || apiRows, JARCount: stats.APICount},
+		SQL:        domain.LogTypePresence{Present: stats.SQLCount > 0 || sqlRows, JARCount: stats.SQLCount},
+		Filter:     domain.LogTypePresence{Present: stats.FilterCount > 0 || filterRows, JARCount: stats.FilterCount},
+		Escalation: domain.LogTypePresence{Present: stats.EscCount > 0 || escRows, JARCount: stats.EscCount},
+	}
+}
+
+func hasGroups(t *domain.JARAggregateTable) bool {
+	return t != nil && len(t.Groups) > 0
+}
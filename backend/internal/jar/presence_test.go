@@ -0,0 +1,52 @@
+package jar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestSectionPresence_AllZero(t *testing.T) {
+	report := "            Total Lines: 10\n              API Count: 0\n              SQL Count: 0\n              ESC Count: 0\n"
+
+	result, err := ParseOutput(report)
+	require.NoError(t, err)
+	require.NotNil(t, result.Sections)
+
+	assert.Equal(t, domain.SectionPresence{}, *result.Sections)
+}
+
+func TestSectionPresence_Partial(t *testing.T) {
+	report := "            Total Lines: 900\n              API Count: 251\n              SQL Count: 0\n              ESC Count: 0\n"
+
+	result, err := ParseOutput(report)
+	require.NoError(t, err)
+
+	sp := result.Sections
+	assert.Equal(t, domain.LogTypePresence{Present: true, JARCount: 251}, sp.API)
+	assert.False(t, sp.SQL.Present)
+	assert.False(t, sp.Filter.Present)
+	assert.False(t, sp.Escalation.Present)
+}
+
+func TestSectionPresence_RowsWithoutCount(t *testing.T) {
+	// A section that produced rows marks its log type present even when the
+	// preamble count is zero or missing.
+	result := &domain.ParseResult{
+		Dashboard: &domain.DashboardData{GeneralStats: domain.GeneralStatistics{SQLCount: 0}},
+		JARAggregates: &domain.JARAggregatesResponse{
+			SQLByTable: &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{{}}},
+			EscByPool:  &domain.JARAggregateTable{},
+		},
+		JARFilters: &domain.JARFilterComplexityResponse{MostExecuted: []domain.JARFilterMostExecuted{{FilterName: "F1"}}},
+	}
+
+	sp := sectionPresence(result)
+	assert.Equal(t, domain.LogTypePresence{Present: true}, sp.SQL)
+	assert.True(t, sp.Filter.Present)
+	assert.False(t, sp.Escalation.Present, "an empty table is not a row")
+	assert.False(t, sp.API.Present)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package jar
+
+import "os/exec"
+
+// setProcessGroup is not available on this platform: the cancellation of
+// a run kills the JAR process alone.
+func setProcessGroup(_ *exec.Cmd) {}
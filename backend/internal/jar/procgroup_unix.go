@@ -0,0 +1,19 @@
+//go:build unix
+
+package jar
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a process group of its own and has the
+// cancellation of its context kill the whole group, so that processes the
+// JAR or its launcher script spawned do not outlive a cancelled run, nor
+// keep its stdout open.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,302 @@
+package jar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// TableFormat is the layout a report format prints its top-N tables in.
+type TableFormat int
+
+const (
+	// TableFormatPipe tables delimit their cells with "|".
+	TableFormatPipe TableFormat = iota + 1
+	// TableFormatFixedWidth tables underline their headers with dashes that
+	// give the width of each column.
+	TableFormatFixedWidth
+)
+
+// ProfileQuirks flags the oddities of a report format.
+type ProfileQuirks struct {
+	// PreambleStats is set when the general statistics are printed before
+	// the first section header instead of in a section of their own.
+	PreambleStats bool
+}
+
+// JARVersion is the version of an ARLogAnalyzer build, as stated in the
+// banner at the top of its report.
+type JARVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v JARVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is an older build than o.
+func (v JARVersion) Less(o JARVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// ordinal places v on a line so that the distance between versions can be
+// measured.
+func (v JARVersion) ordinal() int {
+	return v.Major*1_000_000 + v.Minor*1_000 + v.Patch
+}
+
+// jarVersionRe matches the version in the banner of a report, e.g. "AR
+// System Log Analyzer, version 3.2.2 (for AR server logs versions 9.1.x+)".
+var jarVersionRe = regexp.MustCompile(`(?i)analyzer\b.*?\bversion\s+(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// parseJARVersion returns the analyzer version stated on a banner line.
+func parseJARVersion(line string) (JARVersion, bool) {
+	m := jarVersionRe.FindStringSubmatch(line)
+	if m == nil {
+		return JARVersion{}, false
+	}
+	var parts [3]int
+	for i, s := range m[1:] {
+		if s != "" {
+			parts[i], _ = strconv.Atoi(s)
+		}
+	}
+	return JARVersion{Major: parts[0], Minor: parts[1], Patch: parts[2]}, true
+}
+
+// ParserProfile describes one report format of ARLogAnalyzer: how its
+// section headers look, which parser each section goes to, the layout of
+// its tables and timestamps, and the analyzer builds it was tested with.
+// Profiles are named after the report format rather than the analyzer
+// build: the v4 format is printed by analyzer builds from 3.2 on.
+type ParserProfile struct {
+	Name string
+
+	// MinJAR and MaxJAR bound the analyzer versions the profile was tested
+	// with, MaxJAR excluded.
+	MinJAR, MaxJAR JARVersion
+
+	// HeaderPatterns match the lines that open a section, the section name
+	// being their first submatch.
+	HeaderPatterns []*regexp.Regexp
+
+	// Sections is the section-name matching table: the first rule matching
+	// a section parses it.
+	Sections []SectionRule
+
+	// TableFormat is the table layout expected when a section body looks
+	// like more than one.
+	TableFormat TableFormat
+
+	// TimestampLayouts are the layouts the format prints timestamps in.
+	TimestampLayouts []string
+
+	Quirks ProfileQuirks
+}
+
+// covers reports whether the profile was tested with analyzer version v.
+func (p *ParserProfile) covers(v JARVersion) bool {
+	return !v.Less(p.MinJAR) && v.Less(p.MaxJAR)
+}
+
+// distance is how far v is from the analyzer versions the profile was
+// tested with, 0 when it covers v.
+func (p *ParserProfile) distance(v JARVersion) int {
+	switch {
+	case v.Less(p.MinJAR):
+		return p.MinJAR.ordinal() - v.ordinal()
+	case !v.Less(p.MaxJAR):
+		return v.ordinal() - p.MaxJAR.ordinal() + 1
+	}
+	return 0
+}
+
+// opensSection reports whether line is a section header of the profile.
+func (p *ParserProfile) opensSection(line string) bool {
+	for _, re := range p.HeaderPatterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// section is one section of a report handed to a SectionRule.
+type section struct {
+	profile *ParserProfile
+	// name is the section name as printed, normalized lower-cased and
+	// trimmed.
+	name, normalized string
+	body             []string
+}
+
+// has reports whether the section name holds every one of words.
+func (s section) has(words ...string) bool {
+	for _, w := range words {
+		if !strings.Contains(s.normalized, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// SectionRule routes the sections whose name it matches to a parser.
+type SectionRule struct {
+	// Name describes the sections the rule parses.
+	Name  string
+	match func(s section) bool
+	parse func(result *domain.ParseResult, s section)
+}
+
+var (
+	// profiles are the registered report formats, oldest first.
+	profiles []*ParserProfile
+
+	// headerPatterns are the header patterns of every profile, in
+	// registration order, with which a report is split into sections
+	// before its profile is known.
+	headerPatterns []*regexp.Regexp
+
+	// timestampLayouts are the timestamp layouts of every profile, in
+	// registration order. The section parsers accept any of them, so that
+	// a report parsed with the wrong profile keeps its timestamps.
+	timestampLayouts []string
+)
+
+// registerProfile adds a report format. Profiles are registered oldest
+// first, in the order of the analyzer versions they cover.
+func registerProfile(p *ParserProfile) {
+	if n := len(profiles); n > 0 && p.MinJAR.Less(profiles[n-1].MaxJAR) {
+		panic("jar: profile " + p.Name + " overlaps " + profiles[n-1].Name)
+	}
+	profiles = append(profiles, p)
+	headerPatterns = append(headerPatterns, p.HeaderPatterns...)
+	for _, layout := range p.TimestampLayouts {
+		if !containsString(timestampLayouts, layout) {
+			timestampLayouts = append(timestampLayouts, layout)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Profiles returns the registered report formats, oldest first.
+func Profiles() []*ParserProfile {
+	return append([]*ParserProfile(nil), profiles...)
+}
+
+// LookupProfile returns the registered report format named name.
+func LookupProfile(name string) (*ParserProfile, bool) {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// selectProfile picks the profile of a report from the analyzer version of
+// its banner or, without one, from the style of its first section header.
+// An analyzer version no profile was tested with gets the nearest profile
+// and a warning. A report without either is parsed with the newest
+// profile.
+func selectProfile(version *JARVersion, firstHeader string) (*ParserProfile, string) {
+	if version != nil {
+		nearest := profiles[0]
+		for _, p := range profiles {
+			if p.covers(*version) {
+				return p, ""
+			}
+			if p.distance(*version) < nearest.distance(*version) {
+				nearest = p
+			}
+		}
+		return nearest, fmt.Sprintf(
+			"untested JAR version %s: the report was parsed as the %s format, tested with JAR versions %s to before %s; sections may be missing or misread",
+			version, nearest.Name, nearest.MinJAR, nearest.MaxJAR)
+	}
+	if firstHeader != "" {
+		for _, p := range profiles {
+			if p.opensSection(firstHeader) {
+				return p, ""
+			}
+		}
+	}
+	return profiles[len(profiles)-1], ""
+}
+
+// profileSniffer passes the lines of a report through while noting what
+// selects its profile: the analyzer version of the banner and the first
+// section header.
+type profileSniffer struct {
+	lineSource
+	version     *JARVersion
+	firstHeader string
+}
+
+func (s *profileSniffer) next() (string, bool) {
+	line, ok := s.lineSource.next()
+	if ok && s.firstHeader == "" {
+		if _, header := sectionName(line); header {
+			s.firstHeader = line
+		} else if s.version == nil {
+			if v, found := parseJARVersion(line); found {
+				s.version = &v
+			}
+		}
+	}
+	return line, ok
+}
+
+func init() {
+	registerProfile(&ParserProfile{
+		Name:   "v3",
+		MinJAR: JARVersion{},
+		MaxJAR: JARVersion{Major: 3, Minor: 2},
+		HeaderPatterns: []*regexp.Regexp{
+			sectionHeaderRe,
+		},
+		Sections:    commonSectionRules,
+		TableFormat: TableFormatPipe,
+		TimestampLayouts: []string{
+			"Mon Jan 02 2006 15:04:05.000",
+			"Mon Jan 02 2006 15:04:05",
+			"2006-01-02 15:04:05.000",
+			"2006-01-02 15:04:05",
+			"2006/01/02 15:04:05",
+			"01/02/2006 15:04:05",
+		},
+	})
+	registerProfile(&ParserProfile{
+		Name:   "v4.0",
+		MinJAR: JARVersion{Major: 3, Minor: 2},
+		MaxJAR: JARVersion{Major: 4},
+		HeaderPatterns: []*regexp.Regexp{
+			v4MajorSectionRe,
+			v4SubsectionRe,
+		},
+		Sections:    commonSectionRules,
+		TableFormat: TableFormatFixedWidth,
+		TimestampLayouts: []string{
+			"Mon Jan 02 2006 15:04:05.000",
+			"Mon Jan 02 2006 15:04:05",
+		},
+		Quirks: ProfileQuirks{PreambleStats: true},
+	})
+}
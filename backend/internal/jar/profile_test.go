@@ -0,0 +1,111 @@
+package jar
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	v3Report = "=== General Statistics ===\nAPI Calls: 400\n\n=== User Distribution ===\nDemo: 3\n"
+	v4Report = "Total Lines Processed: 1000\nAPI Calls: 10\n\n" +
+		"###  SECTION: API  ######\n### 50 LONGEST RUNNING INDIVIDUAL API CALLS\n\nnothing\n"
+)
+
+func TestParseJARVersion(t *testing.T) {
+	tests := []struct {
+		line string
+		want JARVersion
+		ok   bool
+	}{
+		{"AR System Log Analyzer, version 3.2.2 (for AR server logs versions 9.1.x+).", JARVersion{3, 2, 2}, true},
+		{"ARLogAnalyzer version 4", JARVersion{Major: 4}, true},
+		{"AR System Log Analyzer, Version 2.10", JARVersion{Major: 2, Minor: 10}, true},
+		{"(for AR server logs versions 9.1.x+)", JARVersion{}, false},
+		{"Total Lines Processed: 1000", JARVersion{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseJARVersion(tt.line)
+		assert.Equal(t, tt.ok, ok, tt.line)
+		assert.Equal(t, tt.want, got, tt.line)
+	}
+}
+
+func TestParseOutput_SelectsProfile(t *testing.T) {
+	log1, err := os.ReadFile("../../testdata/jar_output_log1.txt")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name, report        string
+		profile, jarVersion string
+	}{
+		{"tested v4 build", string(log1), "v4.0", "3.2.2"},
+		{"v3 build", "AR System Log Analyzer, version 2.5.1\n" + v3Report, "v3", "2.5.1"},
+		{"v3 headers without banner", v3Report, "v3", ""},
+		{"v4 headers without banner", v4Report, "v4.0", ""},
+		{"no headers", "Total Lines Processed: 42\n", "v4.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseOutput(tt.report)
+			require.NoError(t, err)
+			assert.Equal(t, tt.profile, result.Profile)
+			assert.Equal(t, tt.jarVersion, result.JARVersion)
+			assert.Empty(t, result.Warnings)
+		})
+	}
+}
+
+func TestParseOutput_UntestedJARVersion(t *testing.T) {
+	result, err := ParseOutput("AR System Log Analyzer, version 5.0.1\n" + v4Report)
+	require.NoError(t, err)
+
+	assert.Equal(t, "v4.0", result.Profile, "the nearest profile is used")
+	assert.Equal(t, "5.0.1", result.JARVersion)
+	require.Len(t, result.Warnings, 1)
+	assert.True(t, strings.HasPrefix(result.Warnings[0], "untested JAR version 5.0.1"), result.Warnings[0])
+	assert.Contains(t, result.Sections.Warnings, result.Warnings[0])
+	assert.Equal(t, 10, int(result.Dashboard.GeneralStats.APICount), "the report is still parsed")
+}
+
+func TestParseOutput_ForcedProfile(t *testing.T) {
+	v3, ok := LookupProfile("v3")
+	require.True(t, ok)
+
+	// The v3 format has no preamble statistics.
+	result, err := ParseOutputWithOptions(v4Report, ParseOptions{Profile: v3})
+	require.NoError(t, err)
+	assert.Equal(t, "v3", result.Profile)
+	assert.Zero(t, result.Dashboard.GeneralStats.APICount)
+
+	result, err = ParseOutput(v4Report)
+	require.NoError(t, err)
+	assert.Equal(t, 10, int(result.Dashboard.GeneralStats.APICount))
+}
+
+func TestProfiles_CoverDisjointVersions(t *testing.T) {
+	all := Profiles()
+	require.NotEmpty(t, all)
+	for i := 1; i < len(all); i++ {
+		assert.False(t, all[i].MinJAR.Less(all[i-1].MaxJAR), "%s overlaps %s", all[i].Name, all[i-1].Name)
+	}
+	_, ok := LookupProfile("v9")
+	assert.False(t, ok)
+}
+
+func TestParseTopNTable_ExpectedFormat(t *testing.T) {
+	// A pipe table whose header is underlined with dashes reads as either
+	// format; the profile's format decides.
+	body := []string{
+		"| Rank | Line# | Timestamp | Thread | Queue | Identifier | Form | User | Duration (ms) | Status |",
+		"--------------------------------------------------------------------------------------------------",
+		"| 1 | 10 | 2026-02-03 10:00:00 | T1 | Fast | GE | HPD:Help Desk | Demo | 5000 | Success |",
+	}
+	entries := parseTopNTable(body, TableFormatPipe)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "HPD:Help Desk", entries[0].Form)
+	assert.Equal(t, int64(5000), int64(entries[0].DurationMS))
+}
@@ -0,0 +1,311 @@
+package jar
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RedactOptions selects the values RedactReport replaces with placeholders.
+type RedactOptions struct {
+	Users      bool `json:"users"`       // values of User columns
+	RequestIDs bool `json:"request_ids"` // transaction, RPC and request IDs
+	Hosts      bool `json:"hosts"`       // host names, IP addresses and the paths of the analysed files
+	Literals   bool `json:"literals"`    // quoted strings, such as the values compared in SQL statements
+}
+
+// RedactAll replaces every kind of value RedactReport knows of.
+var RedactAll = RedactOptions{Users: true, RequestIDs: true, Hosts: true, Literals: true}
+
+// Redaction kinds, in the order they win when they match the same text, and
+// the prefixes of their placeholders.
+const (
+	redactUser = iota
+	redactRequestID
+	redactHost
+	redactLiteral
+)
+
+var redactKinds = [...]struct{ name, prefix string }{
+	redactUser:      {"users", "user"},
+	redactRequestID: {"request_ids", "req"},
+	redactHost:      {"hosts", "host"},
+	redactLiteral:   {"literals", "str"},
+}
+
+// redactColumns maps the lowercased table headers whose values are redacted
+// to their kind.
+var redactColumns = map[string]int{
+	"user":       redactUser,
+	"user name":  redactUser,
+	"trid":       redactRequestID,
+	"rpc id":     redactRequestID,
+	"request id": redactRequestID,
+	"trace id":   redactRequestID,
+	"client ip":  redactHost,
+	"host":       redactHost,
+	"host name":  redactHost,
+	"server":     redactHost,
+}
+
+var (
+	// Transaction IDs: 22 characters of URL-safe base64 and a counter.
+	requestIDRe = regexp.MustCompile(`(?:^|[^A-Za-z0-9_-])([A-Za-z0-9_-]{22}:[0-9]+)`)
+	ipv4Re      = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\b`)
+	// Host names with at least three labels and an alphabetic top level,
+	// which leaves out version numbers and table.column references.
+	hostNameRe    = regexp.MustCompile(`\b(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?\.){2,}[A-Za-z]{2,}\b`)
+	loadingPathRe = regexp.MustCompile(`^Loading ((?:[A-Za-z]:)?[\\/].*)$`)
+	literalRe     = regexp.MustCompile(`'((?:[^']|'')+)'|"([^"]+)"`)
+)
+
+// redactSpan is one value of a line to replace.
+type redactSpan struct {
+	start, end int
+	kind       int
+}
+
+// redactor hands out one placeholder per value and kind, numbered in the
+// order the values first appear in the report.
+type redactor struct {
+	opts         RedactOptions
+	dictionary   [len(redactKinds)]map[string]bool
+	placeholders [len(redactKinds)]map[string]string
+}
+
+func newRedactor(opts RedactOptions) *redactor {
+	r := &redactor{opts: opts}
+	for k := range redactKinds {
+		r.dictionary[k] = make(map[string]bool)
+		r.placeholders[k] = make(map[string]string)
+	}
+	return r
+}
+
+func (r *redactor) enabled(kind int) bool {
+	switch kind {
+	case redactUser:
+		return r.opts.Users
+	case redactRequestID:
+		return r.opts.RequestIDs
+	case redactHost:
+		return r.opts.Hosts
+	case redactLiteral:
+		return r.opts.Literals
+	}
+	return false
+}
+
+// RedactReport replaces the user names, request IDs, host names and quoted
+// literals of a JAR text report with deterministic placeholders: the same
+// value always gets the same placeholder. It returns the redacted report and
+// the number of distinct values replaced per kind.
+//
+// Every placeholder is exactly as many bytes long as the value it replaces
+// and keeps its spaces, tabs and pipes, so the fixed-width columns, which
+// the parser cuts at byte offsets, and the whitespace and pipe separated
+// tables split the same way as in the original. Section headers, table
+// headers and separator lines are left alone.
+func RedactReport(report string, opts RedactOptions) (string, map[string]int) {
+	r := newRedactor(opts)
+	lines := strings.SplitAfter(report, "\n")
+	data := r.collect(lines)
+
+	var b strings.Builder
+	b.Grow(len(report))
+	for i, line := range lines {
+		if !data[i] {
+			b.WriteString(line)
+			continue
+		}
+		body, eol := splitLineEnd(line)
+		b.WriteString(r.redactLine(body))
+		b.WriteString(eol)
+	}
+
+	counts := make(map[string]int)
+	for k, kind := range redactKinds {
+		if n := len(r.placeholders[k]); n > 0 {
+			counts[kind.name] = n
+		}
+	}
+	return b.String(), counts
+}
+
+// collect reports which lines hold data rather than report structure, and
+// adds the values of the redacted columns of every table to the dictionary.
+func (r *redactor) collect(lines []string) []bool {
+	data := make([]bool, len(lines))
+	forEachTable(lines, func(start, end int) {
+		var columns map[int]int
+		var boundaries [][2]int
+		sep := -1
+		for i := start; i < end; i++ {
+			body, _ := splitLineEnd(lines[i])
+			if isDashSeparator(body) || isEqualsSeparator(body) {
+				if sep < 0 && i > start && isDashSeparator(body) {
+					sep = i
+					boundaries = extractColumnBoundaries(body)
+					headerLine, _ := splitLineEnd(lines[i-1])
+					columns = make(map[int]int)
+					for c, h := range extractColumnValues(headerLine, boundaries) {
+						if kind, ok := redactColumns[strings.ToLower(h)]; ok && r.enabled(kind) {
+							columns[c] = kind
+						}
+					}
+					data[i-1] = false
+				}
+				continue
+			}
+			if strings.TrimSpace(body) == "" {
+				continue
+			}
+			data[i] = true
+			if sep < 0 || len(columns) == 0 {
+				continue
+			}
+			values := extractColumnValues(body, boundaries)
+			for c, kind := range columns {
+				if c < len(values) && values[c] != "" {
+					r.dictionary[kind][values[c]] = true
+				}
+			}
+		}
+	})
+	return data
+}
+
+// forEachTable calls fn with the line range of every section of the report,
+// section headers excluded.
+func forEachTable(lines []string, fn func(start, end int)) {
+	start := 0
+	for i, line := range lines {
+		body, _ := splitLineEnd(line)
+		if _, ok := sectionName(body); ok {
+			fn(start, i)
+			start = i + 1
+		}
+	}
+	fn(start, len(lines))
+}
+
+// redactLine replaces the values of one data line.
+func (r *redactor) redactLine(line string) string {
+	var spans []redactSpan
+	add := func(kind, start, end int) {
+		if start < end && r.enabled(kind) {
+			spans = append(spans, redactSpan{start: start, end: end, kind: kind})
+		}
+	}
+
+	for kind, values := range r.dictionary {
+		for value := range values {
+			for _, at := range wordIndexes(line, value) {
+				add(kind, at, at+len(value))
+			}
+		}
+	}
+	for _, m := range requestIDRe.FindAllStringSubmatchIndex(line, -1) {
+		add(redactRequestID, m[2], m[3])
+	}
+	for _, m := range ipv4Re.FindAllStringIndex(line, -1) {
+		add(redactHost, m[0], m[1])
+	}
+	for _, m := range hostNameRe.FindAllStringIndex(line, -1) {
+		add(redactHost, m[0], m[1])
+	}
+	if m := loadingPathRe.FindStringSubmatchIndex(line); m != nil {
+		add(redactHost, m[2], m[3])
+	}
+	for _, m := range literalRe.FindAllStringSubmatchIndex(line, -1) {
+		if m[2] >= 0 {
+			add(redactLiteral, m[2], m[3])
+		} else {
+			add(redactLiteral, m[4], m[5])
+		}
+	}
+	if len(spans) == 0 {
+		return line
+	}
+
+	// Longer values win over the values they contain; of two kinds matching
+	// the same text, the more specific one.
+	sort.Slice(spans, func(i, j int) bool {
+		a, b := spans[i], spans[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.end != b.end {
+			return a.end > b.end
+		}
+		return a.kind < b.kind
+	})
+	var b strings.Builder
+	b.Grow(len(line))
+	pos := 0
+	for _, s := range spans {
+		if s.start < pos {
+			continue
+		}
+		b.WriteString(line[pos:s.start])
+		b.WriteString(r.placeholder(s.kind, line[s.start:s.end]))
+		pos = s.end
+	}
+	b.WriteString(line[pos:])
+	return b.String()
+}
+
+// placeholder returns the placeholder of value: the kind's prefix and the
+// value's number, shortened or padded with 'x' to the byte length of value,
+// with the spaces, tabs and pipes of value kept in place.
+func (r *redactor) placeholder(kind int, value string) string {
+	if p, ok := r.placeholders[kind][value]; ok {
+		return p
+	}
+	n := len(r.placeholders[kind]) + 1
+	token := redactKinds[kind].prefix + strconv.Itoa(n)
+	if len(token) > len(value) {
+		token = strconv.FormatInt(int64(n), 36)
+	}
+	if len(token) > len(value) {
+		token = token[len(token)-len(value):]
+	}
+	p := []byte(token + strings.Repeat("x", len(value)-len(token)))
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case ' ', '\t', '|':
+			p[i] = value[i]
+		}
+	}
+	r.placeholders[kind][value] = string(p)
+	return string(p)
+}
+
+// wordIndexes returns the offsets at which value occurs in line as a whole
+// word, not as part of a longer name.
+func wordIndexes(line, value string) []int {
+	var at []int
+	for from := 0; from < len(line); {
+		i := strings.Index(line[from:], value)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(value)
+		if (start == 0 || !isWordByte(line[start-1])) && (end == len(line) || !isWordByte(line[end])) {
+			at = append(at, start)
+		}
+		from = start + 1
+	}
+	return at
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// splitLineEnd splits a line into its text and its line ending.
+func splitLineEnd(line string) (string, string) {
+	body := strings.TrimRight(line, "\r\n")
+	return body, line[len(body):]
+}
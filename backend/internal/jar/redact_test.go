@@ -0,0 +1,203 @@
+package jar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// padBytes pads s with spaces to n bytes, as the column cut of the parser
+// counts them.
+func padBytes(s string, n int) string {
+	return s + strings.Repeat(" ", max(n-len(s), 0))
+}
+
+// apiErrorsReport is an API errors table whose user names hold multi-byte
+// characters and whose error messages name hosts.
+func apiErrorsReport(rows ...[4]string) string {
+	var b strings.Builder
+	b.WriteString("### API CALLS THAT ERRORED OUT\n\n")
+	b.WriteString("End Line#                           TrID Queue      API        Form        User                                         Start Time Error Message\n")
+	b.WriteString("--------- ------------------------------ ---------- ---------- ----------- -------------------------- ---------------------------- -------------\n")
+	for i, r := range rows {
+		trid, user, form, message := r[0], r[1], r[2], r[3]
+		b.WriteString(padBytes("", 5) + padBytes(string(rune('1'+i))+"211", 5))
+		b.WriteString(" " + strings.Repeat(" ", 30-len(trid)) + trid)
+		b.WriteString(" " + padBytes("Fast", 10) + " " + padBytes("SE", 10) + " " + padBytes(form, 11))
+		b.WriteString(" " + padBytes(user, 26) + " Mon Nov 24 2025 14:47:02.814 " + message + "\n")
+	}
+	return b.String()
+}
+
+func TestRedactor_Placeholder(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  int
+		value string
+		want  string
+	}{
+		{"padded", redactUser, "Demo_Admin", "user1xxxxx"},
+		{"shorter than the prefix", redactUser, "ab", "1x"},
+		{"single byte", redactUser, "a", "1"},
+		{"multi-byte", redactUser, "Jürgen", "user1xx"},
+		{"spaces kept", redactUser, "Remedy Application Service", "user1x xxxxxxxxxxx xxxxxxx"},
+		{"pipes kept", redactRequestID, "000000000000012|000000000000013", "req1xxxxxxxxxxx|xxxxxxxxxxxxxxx"},
+		{"quoted literal", redactLiteral, "it''s", "str1x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRedactor(RedactAll)
+			got := r.placeholder(tt.kind, tt.value)
+			assert.Equal(t, tt.want, got)
+			assert.Len(t, got, len(tt.value), "same width in bytes")
+			assert.Equal(t, got, r.placeholder(tt.kind, tt.value), "same value, same placeholder")
+		})
+	}
+
+	t.Run("numbered in order of appearance", func(t *testing.T) {
+		r := newRedactor(RedactAll)
+		assert.Equal(t, "user1xxx", r.placeholder(redactUser, "Allen.Al"))
+		assert.Equal(t, "user2xxx", r.placeholder(redactUser, "Bob.Berg"))
+		assert.Equal(t, "host1xxx", r.placeholder(redactHost, "10.0.0.1"))
+		assert.Equal(t, "user1xxx", r.placeholder(redactUser, "Allen.Al"))
+	})
+
+	t.Run("numbers past the width", func(t *testing.T) {
+		r := newRedactor(RedactAll)
+		for i := range 40 {
+			r.placeholder(redactUser, strings.Repeat("u", i+3))
+		}
+		assert.Equal(t, "15", r.placeholder(redactUser, "zz"), "41 in base 36")
+	})
+}
+
+func TestRedactReport_FixedWidthColumns(t *testing.T) {
+	report := apiErrorsReport(
+		[4]string{"nZ0UaxoDR9eTQGaKLpHwgQ:0000001", "Jürgen Müller", "SRM:Request", "FAIL -- db01.corp.example.com unreachable"},
+		[4]string{"nZ0UaxoDR9eTQGaKLpHwgQ:0000002", "Ål", "HPD:Help", "FAIL -- login as 'Jürgen Müller' refused"},
+		[4]string{"SsjZsHC9R4a1jxb56Qmy0A:0000316", "Jürgen Müller", "HPD:Help", "FAIL -- from 10.11.19.2"},
+	)
+	redacted, counts := RedactReport(report, RedactAll)
+
+	original := strings.Split(report, "\n")
+	lines := strings.Split(redacted, "\n")
+	require.Len(t, lines, len(original))
+	for i := range original {
+		assert.Len(t, lines[i], len(original[i]), "line %d keeps its width", i+1)
+	}
+	assert.Equal(t, original[:4], lines[:4], "headers and separators untouched")
+	for _, secret := range []string{"Jürgen", "Müller", "Ål", "nZ0UaxoDR9eTQGaKLpHwgQ", "db01.corp.example.com", "10.11.19.2"} {
+		assert.NotContains(t, redacted, secret)
+	}
+	assert.Equal(t, map[string]int{"users": 2, "request_ids": 3, "hosts": 2}, counts,
+		"the quoted user name is redacted as the user")
+
+	want, err := ParseOutput(report)
+	require.NoError(t, err)
+	got, err := ParseOutput(redacted)
+	require.NoError(t, err)
+	wantErrs, gotErrs := want.JARExceptions.APIErrors, got.JARExceptions.APIErrors
+	require.Len(t, gotErrs, 3)
+	for i := range wantErrs {
+		assert.Equal(t, wantErrs[i].EndLine, gotErrs[i].EndLine)
+		assert.Equal(t, wantErrs[i].StartTime, gotErrs[i].StartTime)
+		assert.Equal(t, wantErrs[i].Form, gotErrs[i].Form)
+		assert.Equal(t, wantErrs[i].API, gotErrs[i].API)
+		assert.Len(t, gotErrs[i].User, len(wantErrs[i].User))
+		assert.Len(t, gotErrs[i].TraceID, len(wantErrs[i].TraceID))
+	}
+	assert.Equal(t, gotErrs[0].User, gotErrs[2].User, "same user, same placeholder")
+	assert.NotEqual(t, gotErrs[0].User, gotErrs[1].User)
+	assert.Equal(t, "user1xx xxxxxxx", gotErrs[0].User, "Jürgen is seven bytes long")
+}
+
+func TestRedactReport_WholeValuesOnly(t *testing.T) {
+	report := apiErrorsReport(
+		[4]string{"nZ0UaxoDR9eTQGaKLpHwgQ:0000001", "adm", "SRM:Request", "FAIL -- admin rights of adm revoked"},
+	)
+	redacted, _ := RedactReport(report, RedactOptions{Users: true})
+	assert.Contains(t, redacted, "FAIL -- admin rights of 1xx revoked")
+	assert.Contains(t, redacted, "nZ0UaxoDR9eTQGaKLpHwgQ:0000001", "request IDs left alone")
+}
+
+func TestRedactReport_HeaderNamesAreNotValues(t *testing.T) {
+	// A user named like a column keeps the table header intact.
+	report := apiErrorsReport([4]string{"nZ0UaxoDR9eTQGaKLpHwgQ:0000001", "Queue", "SRM:Request", "FAIL"})
+	redacted, _ := RedactReport(report, RedactAll)
+	lines := strings.Split(redacted, "\n")
+	assert.Contains(t, lines[2], "TrID Queue      API")
+	assert.NotContains(t, lines[4], "Queue")
+}
+
+func TestRedactReport_Options(t *testing.T) {
+	report := "### 50 LONGEST RUNNING INDIVIDUAL SQL CALLS\n\n" +
+		"    Run Time    Line#                           TrID Queue      Table                                            Start Time Success SQL Statement\n" +
+		"------------ -------- ------------------------------ ---------- ------------------------------ ---------------------------- ------- -------------\n" +
+		"       0.016    16474 SsjZsHC9R4a1jxb56Qmy0A:0000316 Fast       ft_pending                     Mon Nov 24 2025 14:47:08.501 true    SELECT COUNT(seqNum) FROM ft_pending WHERE indexServerName = N'ITSMAPP1.citc.gov.sa' AND status = 'Pending approval'\n"
+
+	tests := []struct {
+		name     string
+		opts     RedactOptions
+		kept     []string
+		redacted []string
+	}{
+		{"literals only", RedactOptions{Literals: true},
+			[]string{"SsjZsHC9R4a1jxb56Qmy0A:0000316"},
+			[]string{"ITSMAPP1.citc.gov.sa", "Pending approval"}},
+		{"hosts only", RedactOptions{Hosts: true},
+			[]string{"SsjZsHC9R4a1jxb56Qmy0A:0000316", "Pending approval"},
+			[]string{"ITSMAPP1.citc.gov.sa"}},
+		{"request IDs only", RedactOptions{RequestIDs: true},
+			[]string{"ITSMAPP1.citc.gov.sa", "Pending approval"},
+			[]string{"SsjZsHC9R4a1jxb56Qmy0A"}},
+		{"nothing", RedactOptions{},
+			[]string{"SsjZsHC9R4a1jxb56Qmy0A:0000316", "ITSMAPP1.citc.gov.sa", "Pending approval"},
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redacted, _ := RedactReport(report, tt.opts)
+			assert.Len(t, redacted, len(report))
+			for _, s := range tt.kept {
+				assert.Contains(t, redacted, s)
+			}
+			for _, s := range tt.redacted {
+				assert.NotContains(t, redacted, s)
+			}
+			assert.Contains(t, redacted, "ft_pending", "table names are not redacted")
+		})
+	}
+
+	redacted, _ := RedactReport(report, RedactAll)
+	assert.Contains(t, redacted, "N'host1xxxxxxxxxxxxxxx'", "a quoted host is redacted as a host")
+	assert.Contains(t, redacted, "status = 'str1xxx xxxxxxxx'")
+}
+
+func TestRedactReport_PreambleAndVersions(t *testing.T) {
+	report := "AR System Log Analyzer, version 3.2.2 (for AR server logs versions 9.1.x+).\n" +
+		"ARLogAnalyzer \"error_logs/log1.log\"\n" +
+		"Loading specified files\n" +
+		"Loading /Users/jdoe/captures/arapi.log\n" +
+		"(AR Server 9.1.10.002 202010021144)\n"
+	redacted, _ := RedactReport(report, RedactAll)
+	lines := strings.Split(redacted, "\n")
+	assert.Equal(t, "AR System Log Analyzer, version 3.2.2 (for AR server logs versions 9.1.x+).", lines[0])
+	assert.Equal(t, `ARLogAnalyzer "str1xxxxxxxxxxxxxxx"`, lines[1])
+	assert.Equal(t, "Loading specified files", lines[2])
+	assert.Equal(t, "Loading host1"+strings.Repeat("x", len("/Users/jdoe/captures/arapi.log")-5), lines[3])
+	assert.Equal(t, "(AR Server 9.1.10.002 202010021144)", lines[4], "versions are not addresses")
+}
+
+func TestRedactReport_Deterministic(t *testing.T) {
+	report := apiErrorsReport(
+		[4]string{"nZ0UaxoDR9eTQGaKLpHwgQ:0000001", "Allen", "SRM:Request", "FAIL -- 'x'"},
+		[4]string{"nZ0UaxoDR9eTQGaKLpHwgQ:0000002", "Bob", "SRM:Request", "FAIL -- 'y'"},
+	)
+	first, _ := RedactReport(report, RedactAll)
+	for range 5 {
+		again, _ := RedactReport(report, RedactAll)
+		assert.Equal(t, first, again)
+	}
+}
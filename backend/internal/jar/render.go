@@ -0,0 +1,976 @@
+package jar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// ReportSection names a section of a rendered JAR report.
+type ReportSection string
+
+const (
+	ReportSummary         ReportSection = "summary"
+	ReportFiles           ReportSection = "files"
+	ReportLoggingActivity ReportSection = "logging_activity"
+	ReportGaps            ReportSection = "gaps"
+	ReportLegend          ReportSection = "legend"
+	ReportAPI             ReportSection = "api"
+	ReportSQL             ReportSection = "sql"
+	ReportEscalations     ReportSection = "escalations"
+	ReportFilters         ReportSection = "filters"
+	ReportDistributions   ReportSection = "distributions"
+)
+
+// ReportSections lists every report section in report order.
+var ReportSections = []ReportSection{
+	ReportSummary, ReportFiles, ReportLoggingActivity, ReportGaps, ReportLegend,
+	ReportAPI, ReportSQL, ReportEscalations, ReportFilters, ReportDistributions,
+}
+
+// ParseReportSections validates section names and returns them in report
+// order. No names selects every section.
+func ParseReportSections(names []string) ([]ReportSection, error) {
+	if len(names) == 0 {
+		return ReportSections, nil
+	}
+	want := make(map[ReportSection]bool, len(names))
+	for _, n := range names {
+		s := ReportSection(n)
+		if !hasReportSection(ReportSections, s) {
+			return nil, fmt.Errorf("unknown section %q", n)
+		}
+		want[s] = true
+	}
+	sections := make([]ReportSection, 0, len(want))
+	for _, s := range ReportSections {
+		if want[s] {
+			sections = append(sections, s)
+		}
+	}
+	return sections, nil
+}
+
+func hasReportSection(sections []ReportSection, s ReportSection) bool {
+	for _, x := range sections {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportFormat is the output format of RenderReport.
+type ReportFormat string
+
+const (
+	// ReportText is the fixed-width layout of the JAR itself; ParseOutput
+	// reads it back.
+	ReportText     ReportFormat = "text"
+	ReportMarkdown ReportFormat = "markdown"
+)
+
+// RenderOptions tunes RenderReport.
+type RenderOptions struct {
+	// Format defaults to ReportText.
+	Format ReportFormat
+
+	// Sections selects the sections to render, in any order; nil renders
+	// every section.
+	Sections []ReportSection
+
+	// TopN truncates the ranked tables (top-N calls, gaps, filter rankings
+	// and distributions) to their first TopN rows. Zero keeps every row.
+	TopN int
+}
+
+// reportTimeLayout is the timestamp layout of the JAR's own tables.
+const reportTimeLayout = "Mon Jan 02 2006 15:04:05.000"
+
+// reportTitle opens every rendered report. It must not hold a colon, or
+// the parser would read it as a general statistic.
+const reportTitle = "ARLogAnalyzer report (canonical)"
+
+// RenderReport writes r as a canonical report: the sections in the order
+// of ReportSections, each table laid out the same way whichever JAR version
+// produced r, numbers formatted alike and API codes expanded through the
+// abbreviation legend. ParseOutput reads the text format back to r, less
+// what the parser derives rather than reads, such as expanded API names.
+func RenderReport(w io.Writer, r *domain.ParseResult, opts RenderOptions) error {
+	if r == nil || r.Dashboard == nil {
+		return fmt.Errorf("jar render: no parse result")
+	}
+	sections := opts.Sections
+	if sections == nil {
+		sections = ReportSections
+	}
+
+	rr := reportRenderer{r: r, legend: NewAPILegend(r.APIAbbreviations), topN: opts.TopN}
+	var blocks []reportBlock
+	for _, s := range ReportSections {
+		if hasReportSection(sections, s) {
+			blocks = append(blocks, rr.section(s)...)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	switch opts.Format {
+	case "", ReportText:
+		writeTextReport(bw, blocks)
+	case ReportMarkdown:
+		writeMarkdownReport(bw, blocks)
+	default:
+		return fmt.Errorf("jar render: unknown format %q", opts.Format)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("jar render: %w", err)
+	}
+	return nil
+}
+
+// reportBlock is one subsection of a report. major names the major
+// section it belongs to, if any.
+type reportBlock struct {
+	major string
+	title string
+	table reportTable
+}
+
+// reportTable is a table as both formats lay it out.
+type reportTable struct {
+	cols []reportColumn
+	rows []reportRow
+	// pairs, when set, makes a two-column table a list of
+	// "key<pairs>value" lines in the text format, as the JAR prints its
+	// statistics and legend.
+	pairs string
+}
+
+type reportColumn struct {
+	title string
+	right bool
+}
+
+type reportRow struct {
+	cells []string
+	// total marks a subtotal ('-') or grand total ('=') row, which the text
+	// format draws under a rule of that character.
+	total rune
+}
+
+// reportRenderer builds the blocks of each section of r.
+type reportRenderer struct {
+	r      *domain.ParseResult
+	legend APILegend
+	topN   int
+}
+
+func (rr reportRenderer) section(s ReportSection) []reportBlock {
+	r, data := rr.r, rr.r.Dashboard
+	var blocks []reportBlock
+	add := func(major, title string, t reportTable) {
+		blocks = append(blocks, reportBlock{major: major, title: title, table: t})
+	}
+
+	switch s {
+	case ReportSummary:
+		add("", "General Statistics", summaryTable(data.GeneralStats))
+
+	case ReportFiles:
+		if len(r.FileMetadataList) > 0 {
+			add("", "Input Filenames", filesTable(r.FileMetadataList))
+		}
+
+	case ReportLoggingActivity:
+		if len(r.LoggingActivities) > 0 {
+			add("", "Logging Activity", loggingActivityTable(r.LoggingActivities))
+		}
+
+	case ReportGaps:
+		if r.JARGaps != nil {
+			if len(r.JARGaps.LineGaps) > 0 {
+				add("GAP ANALYSIS", "LONGEST LINE GAPS", gapTable("Line Gap", truncate(r.JARGaps.LineGaps, rr.topN)))
+			}
+			if len(r.JARGaps.ThreadGaps) > 0 {
+				add("GAP ANALYSIS", "LONGEST THREAD GAPS", gapTable("Thread Gap", truncate(r.JARGaps.ThreadGaps, rr.topN)))
+			}
+		}
+
+	case ReportLegend:
+		if len(r.APIAbbreviations) > 0 {
+			add("API", "API Call Abbreviation Legend", legendTable(r.APIAbbreviations))
+		}
+
+	case ReportAPI:
+		if order, ok := data.TopNSort["api"]; ok || len(data.TopAPICalls) > 0 {
+			add("API", topNTitle("LONGEST RUNNING INDIVIDUAL API CALLS", order), rr.topNTable(topNAPI, data.TopAPICalls))
+		}
+		if r.QueuedAPICallsSort != nil || len(r.QueuedAPICalls) > 0 {
+			var order domain.TableSort
+			if r.QueuedAPICallsSort != nil {
+				order = *r.QueuedAPICallsSort
+			}
+			add("API", topNTitle("LONGEST QUEUED INDIVIDUAL API CALLS", order), rr.topNTable(topNAPI, r.QueuedAPICalls))
+		}
+		if agg := r.JARAggregates; agg != nil {
+			for _, t := range []struct {
+				table  *domain.JARAggregateTable
+				entity string
+			}{{agg.APIByForm, "Form"}, {agg.APIByClient, "Client"}, {agg.APIByClientIP, "Client IP"}} {
+				if t.table != nil {
+					add("API", aggregateTitle("API CALL AGGREGATES", t.entity, t.table), rr.aggregateTable(t.entity, "API", t.table))
+				}
+			}
+		}
+		if r.JARThreadStats != nil && len(r.JARThreadStats.APIThreads) > 0 {
+			add("API", "API THREAD STATISTICS BY QUEUE", threadStatsTable(r.JARThreadStats.APIThreads, true))
+		}
+		if exc := r.JARExceptions; exc != nil {
+			if len(exc.APIErrors) > 0 {
+				add("API", "API CALLS THAT ERRORED OUT", rr.apiErrorsTable(exc.APIErrors))
+			}
+			if len(exc.APIExceptions) > 0 {
+				add("API", "API EXCEPTION REPORT", exceptionTable(exc.APIExceptions))
+			}
+		}
+
+	case ReportSQL:
+		if order, ok := data.TopNSort["sql"]; ok || len(data.TopSQL) > 0 {
+			add("SQL", topNTitle("LONGEST RUNNING INDIVIDUAL SQL CALLS", order), rr.topNTable(topNSQL, data.TopSQL))
+		}
+		if r.JARAggregates != nil && r.JARAggregates.SQLByTable != nil {
+			add("SQL", aggregateTitle("SQL CALL AGGREGATES", "Table", r.JARAggregates.SQLByTable), rr.aggregateTable("Table", "SQL", r.JARAggregates.SQLByTable))
+		}
+		if r.JARThreadStats != nil && len(r.JARThreadStats.SQLThreads) > 0 {
+			add("SQL", "SQL THREAD STATISTICS BY QUEUE", threadStatsTable(r.JARThreadStats.SQLThreads, false))
+		}
+		if r.JARExceptions != nil && len(r.JARExceptions.SQLExceptions) > 0 {
+			add("SQL", "SQL EXCEPTION REPORT", exceptionTable(r.JARExceptions.SQLExceptions))
+		}
+
+	case ReportEscalations:
+		if order, ok := data.TopNSort["escalations"]; ok || len(data.TopEscalations) > 0 {
+			add("ESCALATIONS", topNTitle("LONGEST RUNNING INDIVIDUAL ESCALATION CALLS", order), rr.topNTable(topNEscalation, data.TopEscalations))
+		}
+		if agg := r.JARAggregates; agg != nil {
+			for _, t := range []struct {
+				table  *domain.JARAggregateTable
+				entity string
+			}{{agg.EscByForm, "Form"}, {agg.EscByPool, "Pool"}} {
+				if t.table != nil {
+					add("ESCALATIONS", aggregateTitle("Escalation CALL AGGREGATES", t.entity, t.table), rr.aggregateTable(t.entity, "Escalation", t.table))
+				}
+			}
+		}
+
+	case ReportFilters:
+		if order, ok := data.TopNSort["filters"]; ok || len(data.TopFilters) > 0 {
+			add("FILTERS", topNTitle("LONGEST RUNNING INDIVIDUAL FLTR", order), rr.topNTable(topNFilter, data.TopFilters))
+		}
+		if f := r.JARFilters; f != nil {
+			if len(f.MostExecuted) > 0 {
+				add("FILTERS", "MOST EXECUTED FLTR", mostExecutedTable(truncate(f.MostExecuted, rr.topN)))
+			}
+			if len(f.PerTransaction) > 0 {
+				add("FILTERS", "MOST FILTERS PER TRANSACTION", filtersPerTransactionTable(truncate(f.PerTransaction, rr.topN)))
+			}
+			if len(f.ExecutedPerTxn) > 0 {
+				add("FILTERS", "MOST EXECUTED FLTR PER TRANSACTION", executedPerTransactionTable(truncate(f.ExecutedPerTxn, rr.topN)))
+			}
+			if len(f.FilterLevels) > 0 {
+				add("FILTERS", "MOST FILTER LEVELS IN TRANSACTIONS", filterLevelsTable(truncate(f.FilterLevels, rr.topN)))
+			}
+		}
+
+	case ReportDistributions:
+		for _, d := range []struct{ key, title string }{
+			{"threads", "Thread Distribution"},
+			{"errors", "Error Distribution"},
+			{"users", "User Distribution"},
+			{"forms", "Form Distribution"},
+		} {
+			if dist := data.Distribution[d.key]; len(dist) > 0 {
+				add("", d.title, distributionTable(dist, rr.topN))
+			}
+		}
+	}
+	return blocks
+}
+
+// truncate returns the first n rows of rows, or all of them when n is not
+// positive.
+func truncate[T any](rows []T, n int) []T {
+	if n > 0 && len(rows) > n {
+		return rows[:n]
+	}
+	return rows
+}
+
+// topNTitle appends the sort clause to a top-N title when the table is not
+// in the order its title implies.
+func topNTitle(title string, s domain.TableSort) string {
+	if s.SortedBy == "" || s == topNSort(title) {
+		return title
+	}
+	return title + " sorted by " + sortClause(s.SortDirection, s.SortedBy)
+}
+
+// aggregateTitle names an aggregate table by its canonical grouping, with
+// the sort clause of the JAR.
+func aggregateTitle(title, entity string, t *domain.JARAggregateTable) string {
+	title += " grouped by " + entity
+	if t.SortedBy != "" {
+		title += " sorted by " + sortClause(t.SortDirection, t.SortedBy)
+	}
+	return title
+}
+
+func sortClause(dir domain.SortDirection, by string) string {
+	if dir == "" {
+		return by
+	}
+	return string(dir) + " " + by
+}
+
+func formatInt(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+// formatSeconds formats seconds the way the JAR does, to the millisecond.
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
+
+func formatMSAsSeconds(ms int) string {
+	return formatSeconds(float64(ms) / 1000)
+}
+
+func formatTimestamp(t domain.Timestamp) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(reportTimeLayout)
+}
+
+// formatDuration formats a span as "1h 2m 3.456s", which
+// parseDurationToMS reads back exactly.
+func formatDuration(ms int64) string {
+	if ms <= 0 {
+		return "0s"
+	}
+	var parts []string
+	if h := ms / 3_600_000; h > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", h))
+	}
+	if m := ms / 60_000 % 60; m > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", m))
+	}
+	if s := ms % 60_000; s > 0 || len(parts) == 0 {
+		if s%1000 == 0 {
+			parts = append(parts, fmt.Sprintf("%ds", s/1000))
+		} else {
+			parts = append(parts, formatSeconds(float64(s)/1000)+"s")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func summaryTable(st domain.GeneralStatistics) reportTable {
+	t := reportTable{
+		cols:  []reportColumn{{title: "Statistic", right: true}, {title: "Value"}},
+		pairs: ": ",
+	}
+	add := func(key, value string) {
+		if value != "" {
+			t.rows = append(t.rows, reportRow{cells: []string{key, value}})
+		}
+	}
+	add("Total Lines", formatInt(st.TotalLines))
+	add("API Count", formatInt(st.APICount))
+	add("SQL Count", formatInt(st.SQLCount))
+	add("Filter Count", formatInt(st.FilterCount))
+	add("ESC Count", formatInt(st.EscCount))
+	add("User Count", strconv.Itoa(st.UniqueUsers))
+	add("Form Count", strconv.Itoa(st.UniqueForms))
+	add("Table Count", strconv.Itoa(st.UniqueTables))
+	add("Start Time", formatTimestamp(st.LogStart))
+	add("End Time", formatTimestamp(st.LogEnd))
+	add("Elapsed Time", st.LogDuration)
+	queues := make([]string, 0, len(st.ThreadCounts))
+	for queue := range st.ThreadCounts {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+	for _, queue := range queues {
+		add(queue+" Thread Count", strconv.Itoa(st.ThreadCounts[queue]))
+	}
+	return t
+}
+
+func filesTable(files []domain.FileMetadata) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Name"}, {title: "File#", right: true}, {title: "File Start"}, {title: "File End"}, {title: "Duration", right: true},
+	}}
+	for _, f := range files {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			f.FileName, strconv.Itoa(f.FileNumber), formatTimestamp(f.StartTime), formatTimestamp(f.EndTime), formatDuration(f.DurationMS),
+		}})
+	}
+	return t
+}
+
+func loggingActivityTable(activities []domain.LoggingActivity) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Type"}, {title: "First"}, {title: "Last"}, {title: "Duration", right: true},
+	}}
+	for _, a := range activities {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			a.LogType, formatTimestamp(a.FirstTimestamp), formatTimestamp(a.LastTimestamp), formatDuration(a.DurationMS),
+		}})
+	}
+	return t
+}
+
+func gapTable(gapTitle string, gaps []domain.JARGapEntry) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: gapTitle, right: true}, {title: "Line#", right: true}, {title: "TrID", right: true}, {title: "Date/Time", right: true}, {title: "Details"},
+	}}
+	for _, g := range gaps {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			formatSeconds(g.GapDuration), strconv.Itoa(g.LineNumber), g.TraceID, formatTimestamp(g.Timestamp), g.Details,
+		}})
+	}
+	return t
+}
+
+func legendTable(legend []domain.JARAPIAbbreviation) reportTable {
+	t := reportTable{
+		cols:  []reportColumn{{title: "Abbreviation", right: true}, {title: "API"}},
+		pairs: " = ",
+	}
+	for _, a := range legend {
+		t.rows = append(t.rows, reportRow{cells: []string{a.Abbreviation, a.FullName}})
+	}
+	return t
+}
+
+// topNKind selects the columns of a top-N table.
+type topNKind int
+
+const (
+	topNAPI topNKind = iota
+	topNSQL
+	topNEscalation
+	topNFilter
+)
+
+// topNTable lays out a top-N table with the columns of the JAR's v4
+// tables. Columns no entry has a value for are left out, except those the
+// JAR always prints.
+func (rr reportRenderer) topNTable(kind topNKind, entries []domain.TopNEntry) reportTable {
+	entries = truncate(entries, rr.topN)
+
+	var fileNo, rpc, user, details, success bool
+	lastLines := true
+	for _, e := range entries {
+		fileNo = fileNo || e.FileNumber != 0
+		rpc = rpc || e.RPCID != ""
+		user = user || e.User != ""
+		success = success || e.Success
+		if e.Details != "" {
+			details = true
+			if _, ok := lastLine(e.Details); !ok {
+				lastLines = false
+			}
+		}
+	}
+	if kind == topNAPI || kind == topNSQL {
+		success = true
+	}
+
+	type col struct {
+		reportColumn
+		value func(e domain.TopNEntry) string
+	}
+	cols := []col{
+		{reportColumn{title: "Run Time", right: true}, func(e domain.TopNEntry) string { return formatMSAsSeconds(e.DurationMS) }},
+		{reportColumn{title: "Line#", right: true}, func(e domain.TopNEntry) string { return strconv.Itoa(e.LineNumber) }},
+	}
+	if details && lastLines {
+		cols = append(cols, col{reportColumn{title: "Last Line#", right: true}, func(e domain.TopNEntry) string {
+			n, _ := lastLine(e.Details)
+			return n
+		}})
+	}
+	if fileNo {
+		cols = append(cols, col{reportColumn{title: "File#", right: true}, func(e domain.TopNEntry) string { return strconv.Itoa(e.FileNumber) }})
+	}
+	cols = append(cols, col{reportColumn{title: "TrID", right: true}, func(e domain.TopNEntry) string { return e.TraceID }})
+	if rpc {
+		cols = append(cols, col{reportColumn{title: "RPC"}, func(e domain.TopNEntry) string { return e.RPCID }})
+	}
+	queue := "Queue"
+	if kind == topNEscalation {
+		queue = "Pool"
+	}
+	cols = append(cols, col{reportColumn{title: queue}, func(e domain.TopNEntry) string { return e.Queue }})
+
+	identifier := col{reportColumn: reportColumn{}, value: func(e domain.TopNEntry) string { return e.Identifier }}
+	switch kind {
+	case topNAPI:
+		identifier.title = "API"
+	case topNSQL:
+		identifier.title = "SQL Statement"
+	case topNEscalation:
+		identifier.title = "Escalation"
+	case topNFilter:
+		identifier.title = "Filter"
+	}
+	if kind != topNSQL {
+		cols = append(cols, identifier)
+	}
+	if kind == topNAPI {
+		cols = append(cols, col{reportColumn{title: "API Name"}, rr.apiName})
+	}
+	form := "Form"
+	if kind == topNSQL {
+		form = "Table"
+	}
+	cols = append(cols, col{reportColumn{title: form}, func(e domain.TopNEntry) string { return e.Form }})
+	if user {
+		cols = append(cols, col{reportColumn{title: "User"}, func(e domain.TopNEntry) string { return e.User }})
+	}
+	cols = append(cols, col{reportColumn{title: "Start Time", right: true}, func(e domain.TopNEntry) string { return formatTimestamp(e.Timestamp) }})
+	if kind == topNAPI {
+		cols = append(cols, col{reportColumn{title: "Q Time", right: true}, func(e domain.TopNEntry) string { return formatMSAsSeconds(e.QueueTimeMS) }})
+	}
+	if success {
+		cols = append(cols, col{reportColumn{title: "Success"}, func(e domain.TopNEntry) string { return strconv.FormatBool(e.Success) }})
+	}
+	if details && !lastLines {
+		cols = append(cols, col{reportColumn{title: "Details"}, func(e domain.TopNEntry) string { return e.Details }})
+	}
+	if kind == topNSQL {
+		cols = append(cols, identifier)
+	}
+
+	t := reportTable{}
+	for _, c := range cols {
+		t.cols = append(t.cols, c.reportColumn)
+	}
+	for _, e := range entries {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			cells[i] = c.value(e)
+		}
+		t.rows = append(t.rows, reportRow{cells: cells})
+	}
+	return t
+}
+
+// lastLine returns N of the "last_line=N" details the parser records for
+// the Last Line# column.
+func lastLine(details string) (string, bool) {
+	n, ok := strings.CutPrefix(details, "last_line=")
+	if !ok {
+		return "", false
+	}
+	if _, err := strconv.Atoi(n); err != nil {
+		return "", false
+	}
+	return n, true
+}
+
+// apiName is the full name of the API call of e, if known.
+func (rr reportRenderer) apiName(e domain.TopNEntry) string {
+	if e.APIName != "" {
+		return e.APIName
+	}
+	return rr.resolveAPI(e.Identifier)
+}
+
+func (rr reportRenderer) resolveAPI(code string) string {
+	if name, ok := rr.legend.Resolve(code); ok {
+		return name
+	}
+	return ""
+}
+
+// aggregateTable lays out an aggregate table: each group's rows with the
+// entity on the first, then its subtotal, and the grand total last.
+// Escalation tables have a single count column, as the JAR prints them.
+func (rr reportRenderer) aggregateTable(entity, op string, table *domain.JARAggregateTable) reportTable {
+	api := op == "API"
+	counts := op != "Escalation"
+
+	t := reportTable{cols: []reportColumn{{title: entity}, {title: op}}}
+	if api {
+		t.cols = append(t.cols, reportColumn{title: "API Name"})
+	}
+	if counts {
+		t.cols = append(t.cols, reportColumn{title: "OK", right: true}, reportColumn{title: "Fail", right: true}, reportColumn{title: "Total", right: true})
+	} else {
+		t.cols = append(t.cols, reportColumn{title: "Count", right: true})
+	}
+	for _, title := range []string{"MIN Time", "MIN Line", "MAX Time", "MAX Line", "AVG Time", "SUM Time"} {
+		t.cols = append(t.cols, reportColumn{title: title, right: true})
+	}
+
+	row := func(entityName string, r domain.JARAggregateRow, total rune) reportRow {
+		cells := []string{entityName, r.OperationType}
+		if api {
+			apiName := r.APIName
+			if apiName == "" && total == 0 {
+				apiName = rr.resolveAPI(r.OperationType)
+			}
+			cells = append(cells, apiName)
+		}
+		if counts {
+			cells = append(cells, strconv.Itoa(r.OK), strconv.Itoa(r.Fail))
+		}
+		cells = append(cells, strconv.Itoa(r.Total))
+		// The JAR does not time its totals; print only what a total has.
+		timed := func(v string, zero bool) string {
+			if total != 0 && zero {
+				return ""
+			}
+			return v
+		}
+		cells = append(cells,
+			timed(formatSeconds(r.MinTime), r.MinTime == 0),
+			timed(strconv.Itoa(r.MinLine), r.MinLine == 0),
+			timed(formatSeconds(r.MaxTime), r.MaxTime == 0),
+			timed(strconv.Itoa(r.MaxLine), r.MaxLine == 0),
+			timed(formatSeconds(r.AvgTime), r.AvgTime == 0),
+			formatSeconds(r.SumTime),
+		)
+		return reportRow{cells: cells, total: total}
+	}
+
+	for _, g := range table.Groups {
+		if len(g.Rows) == 0 {
+			cells := make([]string, len(t.cols))
+			cells[0] = g.EntityName
+			t.rows = append(t.rows, reportRow{cells: cells})
+		}
+		for i, r := range g.Rows {
+			name := ""
+			if i == 0 {
+				name = g.EntityName
+			}
+			t.rows = append(t.rows, row(name, r, 0))
+		}
+		if g.Subtotal != nil {
+			t.rows = append(t.rows, row("", *g.Subtotal, '-'))
+		}
+	}
+	if table.GrandTotal != nil {
+		t.rows = append(t.rows, row("", *table.GrandTotal, '='))
+	}
+	return t
+}
+
+// threadStatsTable lays out thread statistics with each queue named on
+// its first thread. Only API tables have the queued-call columns.
+func threadStatsTable(stats []domain.JARThreadStat, queued bool) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Queue"}, {title: "Thread", right: true}, {title: "First Thread Time", right: true}, {title: "Last Thread Time", right: true}, {title: "Count", right: true},
+	}}
+	if queued {
+		t.cols = append(t.cols, reportColumn{title: "Q Count", right: true}, reportColumn{title: "Q Time", right: true})
+	}
+	t.cols = append(t.cols, reportColumn{title: "Total Time", right: true}, reportColumn{title: "Busy%", right: true})
+
+	prevQueue := ""
+	for i, s := range stats {
+		queue := s.Queue
+		if i > 0 && queue == prevQueue {
+			queue = ""
+		}
+		prevQueue = s.Queue
+		cells := []string{queue, s.ThreadID, formatTimestamp(s.FirstTime), formatTimestamp(s.LastTime), strconv.Itoa(s.Count)}
+		if queued {
+			cells = append(cells, strconv.Itoa(s.QCount), formatSeconds(s.QTime))
+		}
+		cells = append(cells, formatSeconds(s.TotalTime), strconv.FormatFloat(s.BusyPct, 'f', 2, 64)+"%")
+		t.rows = append(t.rows, reportRow{cells: cells})
+	}
+	return t
+}
+
+func (rr reportRenderer) apiErrorsTable(errs []domain.JARAPIError) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "End Line#", right: true}, {title: "TrID", right: true}, {title: "Queue"}, {title: "API"}, {title: "API Name"},
+		{title: "Form"}, {title: "User"}, {title: "Start Time", right: true}, {title: "Error Message"},
+	}}
+	for _, e := range errs {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			strconv.Itoa(e.EndLine), e.TraceID, e.Queue, e.API, rr.resolveAPI(e.API),
+			e.Form, e.User, formatTimestamp(e.StartTime), e.ErrorMessage,
+		}})
+	}
+	return t
+}
+
+// exceptionTable lays out an API or SQL exception report; the Type and
+// SQL Statement columns are printed when an entry has one.
+func exceptionTable(entries []domain.JARExceptionEntry) reportTable {
+	var typed, sql bool
+	for _, e := range entries {
+		typed = typed || e.Type != ""
+		sql = sql || e.SQLStatement != ""
+	}
+
+	t := reportTable{cols: []reportColumn{{title: "Line#", right: true}, {title: "TrID", right: true}}}
+	if typed {
+		t.cols = append(t.cols, reportColumn{title: "Type", right: true})
+	}
+	t.cols = append(t.cols, reportColumn{title: "Message"})
+	if sql {
+		t.cols = append(t.cols, reportColumn{title: "SQL Statement"})
+	}
+	for _, e := range entries {
+		cells := []string{strconv.Itoa(e.LineNumber), e.TraceID}
+		if typed {
+			cells = append(cells, e.Type)
+		}
+		cells = append(cells, e.Message)
+		if sql {
+			cells = append(cells, e.SQLStatement)
+		}
+		t.rows = append(t.rows, reportRow{cells: cells})
+	}
+	return t
+}
+
+// jarName returns a name as the JAR printed it: truncated names keep their
+// marker, so that the report parses back to the same resolution.
+func jarName(name string, truncated *domain.TruncatedName) string {
+	if truncated != nil {
+		return truncated.Truncated + TruncationMarker
+	}
+	return name
+}
+
+func mostExecutedTable(entries []domain.JARFilterMostExecuted) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Filter"}, {title: "Pass Count", right: true}, {title: "Fail Count", right: true},
+	}}
+	for _, e := range entries {
+		t.rows = append(t.rows, reportRow{cells: []string{jarName(e.FilterName, e.FilterNameTruncated), strconv.Itoa(e.PassCount), strconv.Itoa(e.FailCount)}})
+	}
+	return t
+}
+
+func filtersPerTransactionTable(entries []domain.JARFilterPerTransaction) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Line#", right: true}, {title: "TrID", right: true}, {title: "Filter Count", right: true},
+		{title: "Operation"}, {title: "Form"}, {title: "Request ID"}, {title: "Filters/sec", right: true},
+	}}
+	for _, e := range entries {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			strconv.Itoa(e.LineNumber), e.TraceID, strconv.Itoa(e.FilterCount),
+			e.Operation, jarName(e.Form, e.FormTruncated), e.RequestID, strconv.FormatFloat(e.FiltersPerSec, 'f', 2, 64),
+		}})
+	}
+	return t
+}
+
+func executedPerTransactionTable(entries []domain.JARFilterExecutedPerTxn) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Line#", right: true}, {title: "TrID", right: true}, {title: "Filter"},
+		{title: "Pass Count", right: true}, {title: "Fail Count", right: true},
+	}}
+	for _, e := range entries {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			strconv.Itoa(e.LineNumber), e.TraceID, jarName(e.FilterName, e.FilterNameTruncated), strconv.Itoa(e.PassCount), strconv.Itoa(e.FailCount),
+		}})
+	}
+	return t
+}
+
+func filterLevelsTable(entries []domain.JARFilterLevel) reportTable {
+	t := reportTable{cols: []reportColumn{
+		{title: "Line#", right: true}, {title: "TrID", right: true}, {title: "Filter Level", right: true},
+		{title: "Operation"}, {title: "Form"}, {title: "Request ID"},
+	}}
+	for _, e := range entries {
+		t.rows = append(t.rows, reportRow{cells: []string{
+			strconv.Itoa(e.LineNumber), e.TraceID, strconv.Itoa(e.FilterLevel), e.Operation, jarName(e.Form, e.FormTruncated), e.RequestID,
+		}})
+	}
+	return t
+}
+
+// distributionTable lists a distribution by descending count, then by key.
+func distributionTable(dist map[string]int, topN int) reportTable {
+	keys := make([]string, 0, len(dist))
+	for k := range dist {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if dist[keys[i]] != dist[keys[j]] {
+			return dist[keys[i]] > dist[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	t := reportTable{
+		cols:  []reportColumn{{title: "Name", right: true}, {title: "Count"}},
+		pairs: ": ",
+	}
+	for _, k := range truncate(keys, topN) {
+		t.rows = append(t.rows, reportRow{cells: []string{k, strconv.Itoa(dist[k])}})
+	}
+	return t
+}
+
+// writeTextReport writes blocks in the fixed-width layout of the JAR's v4
+// reports: "###  SECTION: NAME  ###" major headers, "### Title" subsection
+// headers and tables whose columns are marked by the dashed line under
+// their header.
+func writeTextReport(w *bufio.Writer, blocks []reportBlock) {
+	w.WriteString(reportTitle + "\n")
+	major := ""
+	for _, b := range blocks {
+		if b.major != "" && b.major != major {
+			fmt.Fprintf(w, "\n###  SECTION: %s  %s\n", b.major, strings.Repeat("#", 53))
+		}
+		major = b.major
+		fmt.Fprintf(w, "\n### %s\n\n", b.title)
+		writeTextTable(w, b.table)
+	}
+}
+
+func writeTextTable(w *bufio.Writer, t reportTable) {
+	widths := make([]int, len(t.cols))
+	for i, c := range t.cols {
+		if t.pairs == "" {
+			widths[i] = len(c.title)
+		}
+	}
+	for _, r := range t.rows {
+		for i, cell := range r.cells {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	line := func(cells []string) {
+		var sb strings.Builder
+		for i, cell := range cells {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			pad := strings.Repeat(" ", widths[i]-len(cell))
+			if t.cols[i].right {
+				sb.WriteString(pad + cell)
+			} else {
+				sb.WriteString(cell + pad)
+			}
+		}
+		w.WriteString(strings.TrimRight(sb.String(), " ") + "\n")
+	}
+	rule := func(ch rune, cells []string) {
+		ruled := make([]string, len(cells))
+		for i, cell := range cells {
+			if cell != "" {
+				ruled[i] = strings.Repeat(string(ch), widths[i])
+			}
+		}
+		line(ruled)
+	}
+
+	if t.pairs != "" {
+		for _, r := range t.rows {
+			key := r.cells[0]
+			if t.cols[0].right {
+				key = strings.Repeat(" ", widths[0]-len(key)) + key
+			}
+			w.WriteString(key + t.pairs + r.cells[1] + "\n")
+		}
+		return
+	}
+
+	titles := make([]string, len(t.cols))
+	for i, c := range t.cols {
+		titles[i] = c.title
+	}
+	line(titles)
+	all := make([]string, len(t.cols))
+	for i := range all {
+		all[i] = "-"
+	}
+	rule('-', all)
+	for _, r := range t.rows {
+		if r.total != 0 {
+			rule(r.total, r.cells)
+		}
+		line(r.cells)
+	}
+}
+
+// writeMarkdownReport writes blocks as markdown: a heading per major
+// section and per subsection, and a table per subsection.
+func writeMarkdownReport(w *bufio.Writer, blocks []reportBlock) {
+	w.WriteString("# " + reportTitle + "\n")
+	major := ""
+	for _, b := range blocks {
+		level := "##"
+		if b.major != "" {
+			if b.major != major {
+				fmt.Fprintf(w, "\n## %s\n", b.major)
+			}
+			level = "###"
+		}
+		major = b.major
+		fmt.Fprintf(w, "\n%s %s\n\n", level, b.title)
+		writeMarkdownTable(w, b.table)
+	}
+}
+
+func writeMarkdownTable(w *bufio.Writer, t reportTable) {
+	row := func(cells []string) {
+		w.WriteString("|")
+		for _, cell := range cells {
+			w.WriteString(" " + markdownCell(cell) + " |")
+		}
+		w.WriteString("\n")
+	}
+
+	titles := make([]string, len(t.cols))
+	align := make([]string, len(t.cols))
+	for i, c := range t.cols {
+		titles[i] = c.title
+		align[i] = "---"
+		if c.right {
+			align[i] = "---:"
+		}
+	}
+	row(titles)
+	w.WriteString("|")
+	for _, a := range align {
+		w.WriteString(" " + a + " |")
+	}
+	w.WriteString("\n")
+
+	for _, r := range t.rows {
+		cells := r.cells
+		switch r.total {
+		case '-':
+			cells = append([]string{"**Subtotal**"}, cells[1:]...)
+		case '=':
+			cells = append([]string{"**Total**"}, cells[1:]...)
+		}
+		row(cells)
+	}
+}
+
+// markdownCell escapes the characters that would break a table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`).Replace(s)
+}
@@ -0,0 +1,158 @@
+package jar
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files in testdata")
+
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("..", "..", "testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got)
+}
+
+func renderReport(t *testing.T, r *domain.ParseResult, opts RenderOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, RenderReport(&buf, r, opts))
+	return buf.String()
+}
+
+func parseLog1(t *testing.T) *domain.ParseResult {
+	t.Helper()
+	content, err := os.ReadFile("../../testdata/jar_output_log1.txt")
+	require.NoError(t, err)
+	result, err := ParseOutput(string(content))
+	require.NoError(t, err)
+	return result
+}
+
+func TestRenderReport_Golden(t *testing.T) {
+	result := parseLog1(t)
+	assertGolden(t, "jar_report_log1.golden.txt", renderReport(t, result, RenderOptions{}))
+	assertGolden(t, "jar_report_log1.golden.md", renderReport(t, result, RenderOptions{Format: ReportMarkdown}))
+}
+
+// withoutProfile returns a copy of r without the report format it was
+// parsed from: reports always render in the v4 format, without a banner.
+func withoutProfile(r *domain.ParseResult) *domain.ParseResult {
+	c := *r
+	c.Profile, c.JARVersion = "", ""
+	return &c
+}
+
+// TestRenderReport_RoundTrip parses every fixture, renders it and parses the
+// rendering again: the canonical report must hold everything the parser
+// read from the original.
+func TestRenderReport_RoundTrip(t *testing.T) {
+	for name, report := range parserFixtures(t) {
+		t.Run(name, func(t *testing.T) {
+			want, err := ParseOutput(report)
+			require.NoError(t, err)
+
+			rendered := renderReport(t, want, RenderOptions{})
+			got, err := ParseOutput(rendered)
+			require.NoError(t, err)
+			assert.Equal(t, withoutProfile(want), withoutProfile(got), rendered)
+
+			// Rendering is stable: the canonical report renders to itself.
+			assert.Equal(t, rendered, renderReport(t, got, RenderOptions{}))
+		})
+	}
+}
+
+func TestRenderReport_RoundTripsColumnsOutsideTheFixtures(t *testing.T) {
+	ts := domain.NewTimestamp(time.Date(2026, 2, 3, 10, 0, 0, 123e6, time.UTC))
+	want := &domain.ParseResult{Dashboard: &domain.DashboardData{
+		Distribution: map[string]map[string]int{"forms": {"HPD:Help Desk": 10, "CHG:Change": 4}},
+		TopAPICalls: []domain.TopNEntry{{
+			Rank: 1, LineNumber: 10, FileNumber: 2, Timestamp: ts, TraceID: "T1", RPCID: "398", Queue: "Fast",
+			Identifier: "GE", Form: "HPD:Help Desk", User: "Demo", DurationMS: 5000, QueueTimeMS: 12, Success: true,
+			Details: "timed out",
+		}},
+		TopNSort: map[string]domain.TableSort{"api": {SortedBy: "queue time", SortDirection: domain.SortAscending}},
+	}}
+	want.LoggingActivities = []domain.LoggingActivity{{LogType: "API", FirstTimestamp: ts, LastTimestamp: ts, DurationMS: 3_723_456}}
+	want.FileMetadataList = []domain.FileMetadata{{FileNumber: 1, FileName: "arapi.log", StartTime: ts, EndTime: ts, DurationMS: 60_000}}
+	finishParseResult(want)
+
+	rendered := renderReport(t, want, RenderOptions{})
+	assert.Contains(t, rendered, "LONGEST RUNNING INDIVIDUAL API CALLS sorted by ascending queue time")
+	assert.Contains(t, rendered, "1h 2m 3.456s")
+
+	got, err := ParseOutput(rendered)
+	require.NoError(t, err)
+	assert.Equal(t, want, withoutProfile(got), rendered)
+}
+
+func TestRenderReport_ExpandsAPICodes(t *testing.T) {
+	rendered := renderReport(t, parseLog1(t), RenderOptions{Sections: []ReportSection{ReportAPI}})
+	assert.Contains(t, rendered, "ARSetEntry")
+	assert.Contains(t, rendered, "ARGetListEntryWithFields")
+}
+
+func TestRenderReport_SectionsAndTopN(t *testing.T) {
+	result := parseLog1(t)
+	rendered := renderReport(t, result, RenderOptions{Sections: []ReportSection{ReportSQL, ReportSummary}, TopN: 3})
+
+	assert.Contains(t, rendered, "### General Statistics")
+	assert.Contains(t, rendered, "LONGEST RUNNING INDIVIDUAL SQL CALLS")
+	assert.NotContains(t, rendered, "LONGEST RUNNING INDIVIDUAL API CALLS")
+	assert.NotContains(t, rendered, "LONGEST LINE GAPS")
+	assert.Less(t, strings.Index(rendered, "General Statistics"), strings.Index(rendered, "SQL CALLS"), "sections render in report order")
+
+	got, err := ParseOutput(rendered)
+	require.NoError(t, err)
+	assert.Len(t, got.Dashboard.TopSQL, 3)
+	assert.Equal(t, result.Dashboard.TopSQL[:3], got.Dashboard.TopSQL)
+	assert.Equal(t, result.Dashboard.GeneralStats, got.Dashboard.GeneralStats)
+	assert.Nil(t, got.JARGaps)
+}
+
+func TestRenderReport_MarkdownEscapesCells(t *testing.T) {
+	result := &domain.ParseResult{
+		Dashboard: &domain.DashboardData{},
+		JARFilters: &domain.JARFilterComplexityResponse{
+			PerTransaction: []domain.JARFilterPerTransaction{{LineNumber: 1, TraceID: "T1", RequestID: "000001|000002"}},
+		},
+	}
+	rendered := renderReport(t, result, RenderOptions{Format: ReportMarkdown, Sections: []ReportSection{ReportFilters}})
+	assert.Contains(t, rendered, `000001\|000002`)
+}
+
+func TestRenderReport_UnknownFormat(t *testing.T) {
+	err := RenderReport(&bytes.Buffer{}, parseLog1(t), RenderOptions{Format: "pdf"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown format")
+}
+
+func TestParseReportSections(t *testing.T) {
+	sections, err := ParseReportSections(nil)
+	require.NoError(t, err)
+	assert.Equal(t, ReportSections, sections)
+
+	sections, err = ParseReportSections([]string{"filters", "summary", "filters"})
+	require.NoError(t, err)
+	assert.Equal(t, []ReportSection{ReportSummary, ReportFilters}, sections)
+
+	_, err = ParseReportSections([]string{"charts"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"charts"`)
+}
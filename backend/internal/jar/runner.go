@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
@@ -27,6 +28,11 @@ type Result struct {
 	ExitCode int
 	// Duration is the wall-clock time the process ran.
 	Duration time.Duration
+	// CPUTime is the user + system CPU time consumed by the process.
+	CPUTime time.Duration
+	// PeakRSSKB is the peak resident set size of the process in KiB.
+	// Zero on platforms that do not report it.
+	PeakRSSKB int64
 }
 
 // Runner manages execution of ARLogAnalyzer.jar as a subprocess.
@@ -40,6 +46,8 @@ type Runner struct {
 	// javaCmd is the java binary name or path. Defaults to "java".
 	// Exposed for testing so callers can substitute a mock command.
 	javaCmd string
+	// settings, when set, replaces the defaults above at each run.
+	settings config.Dynamic
 }
 
 // NewRunner creates a Runner configured with the given JAR path and defaults.
@@ -67,13 +75,27 @@ func (r *Runner) SetJavaCmd(cmd string) {
 	r.javaCmd = cmd
 }
 
+// SetDynamic makes the runner read its default heap and timeout from
+// settings at each run instead of using the defaults it was created with.
+func (r *Runner) SetDynamic(settings config.Dynamic) {
+	r.settings = settings
+}
+
+// defaults returns the default heap in megabytes and the timeout of a run.
+func (r *Runner) defaults() (heapMB int, timeout time.Duration) {
+	if r.settings != nil {
+		return r.settings.JARHeapMB(), r.settings.JARTimeout()
+	}
+	return r.defaultHeapMB, time.Duration(r.defaultTimeoutSec) * time.Second
+}
+
 // Run executes ARLogAnalyzer.jar with the given flags and returns the result.
 //
 // The heapMB parameter controls the -Xmx setting for this specific run.
-// If heapMB <= 0, the runner's defaultHeapMB is used.
+// If heapMB <= 0, the runner's default heap is used.
 //
 // The provided context controls cancellation. If the context has no deadline,
-// Run applies the runner's defaultTimeoutSec as a deadline. When the deadline
+// Run applies the runner's default timeout as a deadline. When the deadline
 // is reached, the subprocess is killed and an error is returned.
 //
 // The optional lineCallback, if non-nil, is invoked for each line of stdout
@@ -85,14 +107,15 @@ func (r *Runner) Run(
 	heapMB int,
 	lineCallback func(line string),
 ) (*Result, error) {
+	defaultHeapMB, timeout := r.defaults()
 	if heapMB <= 0 {
-		heapMB = r.defaultHeapMB
+		heapMB = defaultHeapMB
 	}
 
 	// Apply default timeout if the context has no deadline.
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(r.defaultTimeoutSec)*time.Second)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
 
@@ -101,6 +124,7 @@ func (r *Runner) Run(
 	cmdArgs := r.buildCommandArgs(heapMB, jarArgs)
 
 	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	setProcessGroup(cmd)
 
 	// Capture stderr into a buffer.
 	var stderrBuf bytes.Buffer
@@ -155,6 +179,10 @@ func (r *Runner) Run(
 		Stderr:   stderrBuf.String(),
 		Duration: duration,
 	}
+	if state := cmd.ProcessState; state != nil {
+		result.CPUTime = state.UserTime() + state.SystemTime()
+		result.PeakRSSKB = peakRSSKB(state)
+	}
 
 	// Determine exit code and error classification.
 	// Check context errors FIRST, because when exec.CommandContext kills a
@@ -196,6 +224,26 @@ func (r *Runner) Run(
 	return result, nil
 }
 
+// versionHeapMB is the heap of the JVM answering Version.
+const versionHeapMB = 256
+
+// Version runs the JAR with --version, a dry run that analyses nothing,
+// and returns what it printed. The output is returned with the error of
+// a run that started but failed, and is empty when the JVM or the JAR
+// could not be started at all.
+func (r *Runner) Version(ctx context.Context) (string, error) {
+	cmdArgs := r.buildCommandArgs(versionHeapMB, []string{"--version"})
+	out, err := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...).CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if ctx.Err() != nil {
+			return output, fmt.Errorf("jar runner: version: %w", ctx.Err())
+		}
+		return output, fmt.Errorf("jar runner: version: %w", err)
+	}
+	return output, nil
+}
+
 // buildCommandArgs constructs the full argument list for exec.Command.
 // When javaCmd is "java", this produces:
 //
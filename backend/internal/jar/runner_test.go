@@ -2,11 +2,16 @@ package jar
 
 import (
 	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -100,6 +105,19 @@ func TestBuildArgs_GroupByOrder(t *testing.T) {
 	assert.Equal(t, expected, args)
 }
 
+func TestBuildArgs_OutputFormat(t *testing.T) {
+	for format, want := range map[string][]string{
+		"":                   {"/tmp/x.log"},
+		domain.JAROutputText: {"/tmp/x.log"},
+		domain.JAROutputXML:  {"-of", "xml", "/tmp/x.log"},
+		domain.JAROutputJSON: {"-of", "json", "/tmp/x.log"},
+		"yaml":               {"/tmp/x.log"},
+	} {
+		args := BuildArgs(domain.JARFlags{OutputFormat: format}, "/tmp/x.log")
+		assert.Equal(t, want, args, "format %q", format)
+	}
+}
+
 func TestBuildArgs_TrimsWhitespace(t *testing.T) {
 	flags := domain.JARFlags{
 		GroupBy:      []string{"  user  ", " ", ""},
@@ -153,6 +171,34 @@ func TestRunner_Run_CapturesStdout(t *testing.T) {
 	assert.True(t, result.Duration > 0, "duration should be positive")
 }
 
+func TestRunner_Version(t *testing.T) {
+	r := NewRunner("/unused.jar", 1024, 30)
+	r.SetJavaCmd("echo")
+
+	out, err := r.Version(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, out, "the output of the dry run is returned")
+
+	r.SetJavaCmd("/nonexistent/java")
+	out, err = r.Version(context.Background())
+	require.Error(t, err)
+	assert.Empty(t, out, "nothing is printed when the JVM cannot start")
+}
+
+func TestRunner_Run_CapturesResourceUsage(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("rusage is only asserted on linux and darwin")
+	}
+	r := NewRunner("/unused.jar", 1024, 30)
+	r.SetJavaCmd("echo")
+
+	result, err := r.Run(context.Background(), "/tmp/test.log", domain.JARFlags{}, 0, nil)
+
+	require.NoError(t, err)
+	assert.Greater(t, result.PeakRSSKB, int64(0), "peak RSS should be reported for the child process")
+	assert.GreaterOrEqual(t, result.CPUTime, time.Duration(0))
+}
+
 func TestRunner_Run_CapturesStdoutWithFlags(t *testing.T) {
 	r := NewRunner("/unused.jar", 1024, 30)
 	r.SetJavaCmd("echo")
@@ -232,6 +278,28 @@ func TestRunner_Run_Cancellation(t *testing.T) {
 	assert.NotNil(t, result)
 }
 
+func TestRunner_Run_CancellationKillsProcessGroup(t *testing.T) {
+	// The script's child holds stdout open: Run only returns promptly if
+	// the child is killed with the script.
+	script := filepath.Join(t.TempDir(), "spawn.sh")
+	require.NoError(t, os.WriteFile(script, []byte("sleep 60 &\nwait\n"), 0o755))
+	r := NewRunner("/unused.jar", 1024, 60)
+	r.SetJavaCmd("sh")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := r.Run(ctx, script, domain.JARFlags{}, 0, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "process cancelled")
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
 func TestRunner_Run_NonZeroExit(t *testing.T) {
 	r := NewRunner("/unused.jar", 1024, 30)
 	// "false" is a Unix command that always exits with code 1.
@@ -274,6 +342,24 @@ func TestRunner_Run_DefaultHeapWhenZero(t *testing.T) {
 	assert.Equal(t, "-Xmx4096m", args[1])
 }
 
+func TestRunner_DynamicDefaults(t *testing.T) {
+	r := NewRunner("/test.jar", 2048, 30)
+	heapMB, timeout := r.defaults()
+	assert.Equal(t, 2048, heapMB)
+	assert.Equal(t, 30*time.Second, timeout)
+
+	settings := config.NewDynamicSettings(&config.Config{JARDefaultHeapMB: 2048, JARTimeoutSec: 60})
+	r.SetDynamic(settings)
+	_, err := settings.Apply(&domain.ServerSettings{Version: 1, Settings: map[string]json.RawMessage{
+		"jar.default_heap_mb": json.RawMessage("6144"),
+		"jar.timeout_sec":     json.RawMessage("3600"),
+	}})
+	require.NoError(t, err)
+	heapMB, timeout = r.defaults()
+	assert.Equal(t, 6144, heapMB)
+	assert.Equal(t, time.Hour, timeout)
+}
+
 func TestRunner_BuildCommandArgs_NonJava(t *testing.T) {
 	r := NewRunner("/test.jar", 2048, 30)
 	r.SetJavaCmd("echo")
@@ -330,6 +416,7 @@ Log Duration:           8h 30m 45s
 	assert.Equal(t, 87, stats.UniqueForms)
 	assert.Equal(t, 35, stats.UniqueTables)
 	assert.Equal(t, "8h 30m 45s", stats.LogDuration)
+	assert.Equal(t, domain.DurationMS(30645000), stats.LogDurationMS)
 	assert.False(t, stats.LogStart.IsZero(), "LogStart should be parsed")
 	assert.False(t, stats.LogEnd.IsZero(), "LogEnd should be parsed")
 }
@@ -651,6 +738,23 @@ func TestParseIntSafe(t *testing.T) {
 	assert.Equal(t, int64(0), parseIntSafe(""))
 }
 
+func TestParseDurationToMS(t *testing.T) {
+	tests := map[string]int64{
+		"8h 30m 45s": 30645000,
+		"1d 2h":      93600000,
+		"12.877s":    12877,
+		"500ms":      500,
+		"1m 500ms":   60500,
+		"10.162":     10162,
+		"02:30:00":   9000000,
+		"":           0,
+		"n/a":        0,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, parseDurationToMS(in), in)
+	}
+}
+
 func TestParseTimestampSafe(t *testing.T) {
 	ts := parseTimestampSafe("Mon Feb 03 2026 10:00:00.123")
 	assert.False(t, ts.IsZero())
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package jar
+
+import "os"
+
+// peakRSSKB is not available on this platform.
+func peakRSSKB(_ *os.ProcessState) int64 {
+	return 0
+}
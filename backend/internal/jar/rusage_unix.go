@@ -0,0 +1,23 @@
+//go:build unix
+
+package jar
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakRSSKB returns the peak resident set size of an exited process in KiB,
+// read from the rusage reported by wait(2).
+func peakRSSKB(state *os.ProcessState) int64 {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return 0
+	}
+	// Darwin reports ru_maxrss in bytes; Linux and the BSDs use KiB.
+	if runtime.GOOS == "darwin" {
+		return int64(ru.Maxrss) / 1024
+	}
+	return int64(ru.Maxrss)
+}
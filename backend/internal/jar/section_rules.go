@@ -0,0 +1,297 @@
+package jar
+
+import "github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+
+// commonSectionRules is the section-name matching table of the v3 and v4
+// report formats. Rules are tried in order, so the more specific names come
+// first and the generic v3 distributions last.
+var commonSectionRules = []SectionRule{
+	// v4 prints the general statistics before any section.
+	{
+		Name:  "preamble",
+		match: func(s section) bool { return s.name == preambleSection },
+		parse: func(r *domain.ParseResult, s section) {
+			if s.profile.Quirks.PreambleStats {
+				parseGeneralStatistics(s.body, &r.Dashboard.GeneralStats)
+			}
+		},
+	},
+	// v3: "General Statistics"
+	{
+		Name:  "general statistics",
+		match: func(s section) bool { return s.has("general statistic") },
+		parse: func(r *domain.ParseResult, s section) {
+			parseGeneralStatistics(s.body, &r.Dashboard.GeneralStats)
+		},
+	},
+
+	// --- GAP ANALYSIS ---
+	{
+		Name:  "line gaps",
+		match: func(s section) bool { return s.has("longest line gap") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseGapEntries(s.body); len(entries) > 0 {
+				jarGaps(r).LineGaps = entries
+			}
+		},
+	},
+	{
+		Name:  "thread gaps",
+		match: func(s section) bool { return s.has("longest thread gap") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseGapEntries(s.body); len(entries) > 0 {
+				jarGaps(r).ThreadGaps = entries
+			}
+		},
+	},
+
+	// --- ABBREVIATION LEGEND ---
+	{
+		Name:  "abbreviation legend",
+		match: func(s section) bool { return s.has("abbreviation legend") },
+		parse: func(r *domain.ParseResult, s section) {
+			r.APIAbbreviations = parseAPIAbbreviationLegend(s.body)
+		},
+	},
+
+	// --- API TOP-N ---
+	{
+		Name:  "top api calls",
+		match: func(s section) bool { return s.has("top", "api") || s.has("longest", "running", "api") },
+		parse: func(r *domain.ParseResult, s section) {
+			r.Dashboard.TopAPICalls = parseTopNTable(s.body, s.profile.TableFormat)
+			setTopNSort(r.Dashboard, "api", s.name)
+		},
+	},
+
+	// --- QUEUED API CALLS ---
+	{
+		Name:  "queued api calls",
+		match: func(s section) bool { return s.has("queued", "api") },
+		parse: func(r *domain.ParseResult, s section) {
+			if !sectionContainsNoData(s.body) {
+				r.QueuedAPICalls = parseTopNTable(s.body, s.profile.TableFormat)
+				r.JARQueuedAPICalls = parseQueuedAPICalls(s.body)
+				sort := topNSort(s.name)
+				r.QueuedAPICallsSort = &sort
+			}
+		},
+	},
+
+	// --- API AGGREGATES ---
+	aggregateRule("api aggregates by form", []string{"api call aggregates", "by form"}, func(a *domain.JARAggregatesResponse, t *domain.JARAggregateTable) { a.APIByForm = t }),
+	aggregateRule("api aggregates by client ip", []string{"api call aggregates", "by client ip"}, func(a *domain.JARAggregatesResponse, t *domain.JARAggregateTable) { a.APIByClientIP = t }),
+	aggregateRule("api aggregates by client", []string{"api call aggregates", "by client"}, func(a *domain.JARAggregatesResponse, t *domain.JARAggregateTable) { a.APIByClient = t }),
+
+	// --- API THREAD STATISTICS ---
+	{
+		Name:  "api thread statistics",
+		match: func(s section) bool { return s.has("api thread statistics") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseThreadStatsTable(s.body); len(entries) > 0 {
+				jarThreadStats(r).APIThreads = entries
+			}
+		},
+	},
+
+	// --- API ERRORS ---
+	{
+		Name:  "api errors",
+		match: func(s section) bool { return s.has("errored out") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseAPIErrors(s.body); len(entries) > 0 {
+				jarExceptions(r).APIErrors = entries
+			}
+		},
+	},
+
+	// --- API EXCEPTION REPORT ---
+	{
+		Name:  "api exceptions",
+		match: func(s section) bool { return s.has("api exception report") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseExceptionReport(s.body); len(entries) > 0 {
+				jarExceptions(r).APIExceptions = entries
+			}
+		},
+	},
+
+	// --- SQL TOP-N ---
+	{
+		Name:  "top sql",
+		match: func(s section) bool { return s.has("top", "sql") || s.has("longest", "running", "sql") },
+		parse: func(r *domain.ParseResult, s section) {
+			r.Dashboard.TopSQL = parseTopNTable(s.body, s.profile.TableFormat)
+			setTopNSort(r.Dashboard, "sql", s.name)
+		},
+	},
+
+	// --- SQL AGGREGATES ---
+	aggregateRule("sql aggregates by table", []string{"sql call aggregates", "by table"}, func(a *domain.JARAggregatesResponse, t *domain.JARAggregateTable) { a.SQLByTable = t }),
+
+	// --- SQL THREAD STATISTICS ---
+	{
+		Name:  "sql thread statistics",
+		match: func(s section) bool { return s.has("sql thread statistics") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseThreadStatsTable(s.body); len(entries) > 0 {
+				jarThreadStats(r).SQLThreads = entries
+			}
+		},
+	},
+
+	// --- SQL EXCEPTION REPORT ---
+	{
+		Name:  "sql exceptions",
+		match: func(s section) bool { return s.has("sql exception report") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseExceptionReport(s.body); len(entries) > 0 {
+				jarExceptions(r).SQLExceptions = entries
+			}
+		},
+	},
+
+	// --- ESCALATION TOP-N ---
+	{
+		Name: "top escalations",
+		match: func(s section) bool {
+			return s.has("top", "escalation") ||
+				s.has("longest", "running") && (s.has("escl") || s.has("escalation"))
+		},
+		parse: func(r *domain.ParseResult, s section) {
+			r.Dashboard.TopEscalations = parseTopNTable(s.body, s.profile.TableFormat)
+			setTopNSort(r.Dashboard, "escalations", s.name)
+		},
+	},
+
+	// --- ESCALATION AGGREGATES ---
+	aggregateRule("escalation aggregates by form", []string{"escalation call aggregates", "by form"}, func(a *domain.JARAggregatesResponse, t *domain.JARAggregateTable) { a.EscByForm = t }),
+	aggregateRule("escalation aggregates by pool", []string{"escalation call aggregates", "by pool"}, func(a *domain.JARAggregatesResponse, t *domain.JARAggregateTable) { a.EscByPool = t }),
+
+	// --- FILTER TOP-N (longest running) ---
+	{
+		Name:  "top filters",
+		match: func(s section) bool { return s.has("top", "filter") || s.has("longest", "running", "fltr") },
+		parse: func(r *domain.ParseResult, s section) {
+			r.Dashboard.TopFilters = parseTopNTable(s.body, s.profile.TableFormat)
+			setTopNSort(r.Dashboard, "filters", s.name)
+		},
+	},
+
+	// --- FILTER: MOST EXECUTED PER TRANSACTION (must match before "most executed fltr") ---
+	{
+		Name:  "filters executed per transaction",
+		match: func(s section) bool { return s.has("most executed fltr per transaction") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseFilterExecutedPerTxn(s.body); len(entries) > 0 {
+				jarFilters(r).ExecutedPerTxn = entries
+			}
+		},
+	},
+
+	// --- FILTER: MOST EXECUTED ---
+	{
+		Name:  "most executed filters",
+		match: func(s section) bool { return s.has("most executed fltr") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseMostExecutedFilters(s.body); len(entries) > 0 {
+				jarFilters(r).MostExecuted = entries
+			}
+		},
+	},
+
+	// --- FILTER: MOST FILTERS PER TRANSACTION ---
+	{
+		Name:  "filters per transaction",
+		match: func(s section) bool { return s.has("most filters per transaction") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseFilterPerTransaction(s.body); len(entries) > 0 {
+				jarFilters(r).PerTransaction = entries
+			}
+		},
+	},
+
+	// --- FILTER: MOST FILTER LEVELS ---
+	{
+		Name:  "filter levels",
+		match: func(s section) bool { return s.has("most filter levels") },
+		parse: func(r *domain.ParseResult, s section) {
+			if entries := parseFilterLevels(s.body); len(entries) > 0 {
+				jarFilters(r).FilterLevels = entries
+			}
+		},
+	},
+
+	// --- LOGGING ACTIVITY ---
+	{
+		Name:  "logging activity",
+		match: func(s section) bool { return s.has("logging activity") },
+		parse: func(r *domain.ParseResult, s section) {
+			if activities := parseLoggingActivity(s.body); len(activities) > 0 {
+				r.LoggingActivities = activities
+			}
+		},
+	},
+
+	// --- FILE INFORMATION / INPUT FILENAMES ---
+	{
+		Name:  "file information",
+		match: func(s section) bool { return s.has("input filename") || s.has("file information") },
+		parse: func(r *domain.ParseResult, s section) {
+			if files := parseFileMetadata(s.body); len(files) > 0 {
+				r.FileMetadataList = files
+			}
+		},
+	},
+
+	// --- GENERIC FALLBACKS (v3 compatibility) ---
+	{
+		Name: "thread distribution",
+		match: func(s section) bool {
+			return s.has("thread") && !s.has("gap") && !s.has("api thread") && !s.has("sql thread")
+		},
+		parse: distributionParser("threads"),
+	},
+	{
+		Name: "error distribution",
+		match: func(s section) bool {
+			return (s.has("exception") || s.has("error")) &&
+				!s.has("errored out") && !s.has("api exception") && !s.has("sql exception")
+		},
+		parse: distributionParser("errors"),
+	},
+	{
+		Name:  "user distribution",
+		match: func(s section) bool { return s.has("user") && !s.has("count") },
+		parse: distributionParser("users"),
+	},
+	{
+		Name: "form distribution",
+		match: func(s section) bool {
+			return s.has("form") && !s.has("count") && !s.has("longest") && !s.has("aggregates")
+		},
+		parse: distributionParser("forms"),
+	},
+}
+
+// aggregateRule parses the aggregate sections whose name holds every one
+// of words into the table set picks.
+func aggregateRule(name string, words []string, set func(*domain.JARAggregatesResponse, *domain.JARAggregateTable)) SectionRule {
+	return SectionRule{
+		Name:  name,
+		match: func(s section) bool { return s.has(words...) },
+		parse: func(r *domain.ParseResult, s section) {
+			if table := parseAggregateSection(s.name, s.body); table != nil {
+				set(jarAggregates(r), table)
+			}
+		},
+	}
+}
+
+// distributionParser parses a v3 "name: count" section into the dashboard
+// distribution of category.
+func distributionParser(category string) func(*domain.ParseResult, section) {
+	return func(r *domain.ParseResult, s section) {
+		parseDistribution(s.body, r.Dashboard, category)
+	}
+}
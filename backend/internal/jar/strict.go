@@ -0,0 +1,317 @@
+package jar
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// Reasons a table row is reported by strict parsing.
+const (
+	ReasonNoSeparator    = "no separator found"
+	ReasonBadTimestamp   = "timestamp unparseable"
+	ReasonColumnMismatch = "column count mismatch"
+)
+
+const (
+	// maxRowErrors caps the row errors kept by one strict parse; the rest
+	// are only counted.
+	maxRowErrors = 200
+
+	// maxRowErrorLineBytes caps the line content kept with a row error.
+	maxRowErrorLineBytes = 512
+)
+
+// diagnostics collects the ParseDiagnostics of a strict parse section by
+// section.
+type diagnostics struct {
+	d *domain.ParseDiagnostics
+}
+
+func newDiagnostics() *diagnostics {
+	return &diagnostics{d: &domain.ParseDiagnostics{}}
+}
+
+// observe records one section once parseSection has handled it. The row
+// checks work on the table format of body, independently of the section
+// parsers; the rows parsed are counted by parsing body again on its own,
+// so that sections writing to the same field do not mask each other.
+// Sections without content, such as v4 major section headers, are not
+// counted.
+func (g *diagnostics) observe(profile *ParserProfile, name string, body []string, recognized bool) {
+	if blank(body) {
+		return
+	}
+	g.d.SectionsSeen++
+	if !recognized {
+		g.d.SkippedSections = append(g.d.SkippedSections, strings.Clone(name))
+		return
+	}
+	g.d.SectionsRecognized++
+
+	// General statistics are free-form key-value text mixed with the JAR
+	// banner, and empty sections say so in a sentence.
+	normalized := strings.ToLower(name)
+	if name == preambleSection || strings.Contains(normalized, "general statistic") || sectionContainsNoData(body) {
+		return
+	}
+
+	present := checkRows(body, func(line, reason string) {
+		g.rowError(name, line, reason)
+	})
+
+	scratch := &domain.ParseResult{Dashboard: &domain.DashboardData{Distribution: make(map[string]map[string]int)}}
+	parseSection(scratch, profile, name, body)
+	parsed := parsedRows(scratch)
+
+	if present == 0 && parsed == 0 {
+		return
+	}
+	g.d.Tables = append(g.d.Tables, domain.TableCoverage{
+		Section:     strings.Clone(name),
+		RowsPresent: present,
+		RowsParsed:  parsed,
+	})
+	g.d.RowsPresent += present
+	g.d.RowsParsed += parsed
+}
+
+// blank reports whether a section body has no content.
+func blank(body []string) bool {
+	for _, line := range body {
+		if strings.TrimSpace(line) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *diagnostics) rowError(section, line, reason string) {
+	if len(g.d.RowErrors) >= maxRowErrors {
+		g.d.RowErrorsDropped++
+		return
+	}
+	line = strings.TrimSpace(line)
+	if len(line) > maxRowErrorLineBytes {
+		line = strings.ToValidUTF8(line[:maxRowErrorLineBytes], "")
+	}
+	g.d.RowErrors = append(g.d.RowErrors, domain.ParseRowError{
+		Section: strings.Clone(section),
+		Line:    strings.Clone(line),
+		Reason:  reason,
+	})
+}
+
+// checkRows detects the table format of a section body the way the section
+// parsers do, reports the data rows that do not fit it and returns the
+// number of data rows present.
+func checkRows(body []string, report func(line, reason string)) int {
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 3 {
+			return checkPipeRows(body, report)
+		}
+	}
+	for _, line := range body {
+		if isDashSeparator(line) {
+			return checkFixedWidthRows(body, report)
+		}
+	}
+	for _, line := range body {
+		if fields := splitFields(strings.TrimSpace(line)); len(fields) > 0 {
+			if _, err := strconv.Atoi(fields[0]); err == nil {
+				return checkWhitespaceRows(body, report)
+			}
+		}
+	}
+	return checkKeyValueRows(body, report)
+}
+
+// checkPipeRows checks the rows of a pipe-delimited table against the cell
+// count of its header row.
+func checkPipeRows(body []string, report func(line, reason string)) int {
+	var headers []string
+	present := 0
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "|") || separatorRe.MatchString(trimmed) {
+			continue
+		}
+		cells := splitPipeCells(trimmed)
+		if isSeparatorRow(cells) {
+			continue
+		}
+		if headers == nil {
+			headers = cells
+			continue
+		}
+
+		present++
+		if len(cells) != len(headers) {
+			report(line, ReasonColumnMismatch)
+			continue
+		}
+		if !timestampsParse(headers, cells) {
+			report(line, ReasonBadTimestamp)
+		}
+	}
+	return present
+}
+
+// checkFixedWidthRows checks the rows of dash-aligned tables. A separator
+// starting in the first column underlines the header of a new table;
+// indented dash or equals rules underline subtotal and total rows, which
+// are not data rows.
+func checkFixedWidthRows(body []string, report func(line, reason string)) int {
+	var headers []string
+	var boundaries [][2]int
+	present := 0
+	skipTotal := false
+
+	for i, line := range body {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case isDashSeparator(line) && !strings.HasPrefix(line, " "):
+			continue
+		case i+1 < len(body) && isDashSeparator(body[i+1]) && !strings.HasPrefix(body[i+1], " "):
+			boundaries = extractColumnBoundaries(body[i+1])
+			headers = extractColumnValues(line, boundaries)
+			skipTotal = false
+			continue
+		case boundaries == nil:
+			continue
+		case isDashSeparator(line) || isEqualsSeparator(line):
+			skipTotal = true
+			continue
+		case skipTotal:
+			skipTotal = false
+			continue
+		}
+
+		present++
+		if !alignedToColumns(line, boundaries) {
+			report(line, ReasonColumnMismatch)
+			continue
+		}
+		if !timestampsParse(headers, extractColumnValues(line, boundaries)) {
+			report(line, ReasonBadTimestamp)
+		}
+	}
+	return present
+}
+
+// alignedToColumns reports whether line leaves the gaps between the columns
+// of a dash-aligned table blank. Text in a gap means a value overflowed its
+// column, so the values around it are cut in the wrong place.
+func alignedToColumns(line string, boundaries [][2]int) bool {
+	for i := 0; i+1 < len(boundaries); i++ {
+		for pos := boundaries[i][1]; pos < boundaries[i+1][0] && pos < len(line); pos++ {
+			if line[pos] != ' ' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// timestampsParse reports whether the values of the timestamp columns of a
+// row parse. Columns are timestamps when their header names a time or date
+// and their value is not a number of seconds, like "Run Time".
+func timestampsParse(headers, values []string) bool {
+	for i, h := range headers {
+		if i >= len(values) || values[i] == "" {
+			continue
+		}
+		h = strings.ToLower(h)
+		if !strings.Contains(h, "time") && !strings.Contains(h, "date") {
+			continue
+		}
+		if _, err := strconv.ParseFloat(values[i], 64); err == nil {
+			continue
+		}
+		if _, ok := tryParseTimestamp(values[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWhitespaceRows checks the ranked rows of a whitespace-aligned top-N
+// table. Lines not starting with a rank are headers.
+func checkWhitespaceRows(body []string, report func(line, reason string)) int {
+	present := 0
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || separatorRe.MatchString(trimmed) {
+			continue
+		}
+		fields := splitFields(trimmed)
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+
+		present++
+		entry, ok := parseTopNLine(trimmed)
+		switch {
+		case !ok:
+			report(line, ReasonColumnMismatch)
+		case entry.Timestamp.IsZero():
+			report(line, ReasonBadTimestamp)
+		}
+	}
+	return present
+}
+
+// checkKeyValueRows checks that every line of a key-value section has a
+// colon, equals sign or tab between key and value.
+func checkKeyValueRows(body []string, report func(line, reason string)) int {
+	present := 0
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || separatorRe.MatchString(trimmed) || isEqualsSeparator(trimmed) {
+			continue
+		}
+		present++
+		if !strings.ContainsAny(trimmed, ":=\t") {
+			report(line, ReasonNoSeparator)
+		}
+	}
+	return present
+}
+
+// parsedRows counts the rows a parse produced over every table it fills.
+func parsedRows(r *domain.ParseResult) int {
+	d := r.Dashboard
+	n := len(d.TopAPICalls) + len(d.TopSQL) + len(d.TopFilters) + len(d.TopEscalations)
+	for _, dist := range d.Distribution {
+		n += len(dist)
+	}
+	n += len(r.QueuedAPICalls) + len(r.APIAbbreviations) + len(r.LoggingActivities) + len(r.FileMetadataList)
+
+	if g := r.JARGaps; g != nil {
+		n += len(g.LineGaps) + len(g.ThreadGaps)
+	}
+	if a := r.JARAggregates; a != nil {
+		for _, t := range []*domain.JARAggregateTable{a.APIByForm, a.APIByClient, a.APIByClientIP, a.SQLByTable, a.EscByForm, a.EscByPool} {
+			if t == nil {
+				continue
+			}
+			for _, g := range t.Groups {
+				n += len(g.Rows)
+			}
+		}
+	}
+	if t := r.JARThreadStats; t != nil {
+		n += len(t.APIThreads) + len(t.SQLThreads)
+	}
+	if e := r.JARExceptions; e != nil {
+		n += len(e.APIErrors) + len(e.APIExceptions) + len(e.SQLExceptions)
+	}
+	if f := r.JARFilters; f != nil {
+		n += len(f.MostExecuted) + len(f.PerTransaction) + len(f.ExecutedPerTxn) + len(f.FilterLevels)
+	}
+	return n
+}
@@ -0,0 +1,209 @@
+package jar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// strictCoverageFloors are the row coverages below which a fixture counts
+// as a parser regression. Fixtures not listed must be parsed completely.
+var strictCoverageFloors = map[string]float64{
+	// Three API aggregate rows have no form name and are dropped.
+	"jar_output_log1.txt": 0.99,
+	// The API section body is deliberately not a table.
+	"v4 preamble and subsections": 0,
+}
+
+// rowCoverage is the share of present rows that were parsed. A table that
+// yields more rows than it holds does not make up for another one.
+func rowCoverage(d *domain.ParseDiagnostics) float64 {
+	present, parsed := 0, 0
+	for _, t := range d.Tables {
+		present += t.RowsPresent
+		parsed += min(t.RowsParsed, t.RowsPresent)
+	}
+	if present == 0 {
+		return 1
+	}
+	return float64(parsed) / float64(present)
+}
+
+// requireStrictCoverage parses report in strict mode and fails when a
+// section goes unrecognized or the row coverage drops below floor.
+func requireStrictCoverage(t *testing.T, report string, floor float64) {
+	t.Helper()
+	result, err := ParseOutputWithOptions(report, ParseOptions{Strict: true})
+	require.NoError(t, err)
+	d := result.Diagnostics
+	require.NotNil(t, d)
+
+	assert.Empty(t, d.SkippedSections, "unrecognized sections")
+	assert.Equal(t, d.SectionsSeen, d.SectionsRecognized)
+	if cov := rowCoverage(d); cov < floor {
+		t.Errorf("row coverage %.4f below %.4f; tables %+v; row errors %+v", cov, floor, d.Tables, d.RowErrors)
+	}
+}
+
+func TestParseOutput_StrictCoverage(t *testing.T) {
+	for name, report := range parserFixtures(t) {
+		t.Run(name, func(t *testing.T) {
+			floor, ok := strictCoverageFloors[name]
+			if !ok {
+				floor = 1
+			}
+			requireStrictCoverage(t, report, floor)
+		})
+	}
+}
+
+func TestParseOutput_StrictLeavesResultUnchanged(t *testing.T) {
+	for name, report := range parserFixtures(t) {
+		t.Run(name, func(t *testing.T) {
+			want, err := ParseOutput(report)
+			require.NoError(t, err)
+			assert.Nil(t, want.Diagnostics, "diagnostics are only collected in strict mode")
+
+			got, err := ParseOutputWithOptions(report, ParseOptions{Strict: true})
+			require.NoError(t, err)
+			require.NotNil(t, got.Diagnostics)
+			got.Diagnostics = nil
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestParseOutput_StrictDiagnostics(t *testing.T) {
+	tests := []struct {
+		name        string
+		report      string
+		wantSkipped []string
+		wantErrors  []domain.ParseRowError
+		wantTables  []domain.TableCoverage
+	}{
+		{
+			name:        "unknown section",
+			report:      "=== User Distribution ===\nDemo: 3\n\n=== Licence Usage ===\nfixed: 4\n\n=== Empty ===\n\n",
+			wantSkipped: []string{"Licence Usage"},
+			wantTables:  []domain.TableCoverage{{Section: "User Distribution", RowsPresent: 1, RowsParsed: 1}},
+		},
+		{
+			name:   "key-value line without separator",
+			report: "=== User Distribution ===\nDemo: 3\nAllen 2\n",
+			wantErrors: []domain.ParseRowError{
+				{Section: "User Distribution", Line: "Allen 2", Reason: ReasonNoSeparator},
+			},
+			wantTables: []domain.TableCoverage{{Section: "User Distribution", RowsPresent: 2, RowsParsed: 1}},
+		},
+		{
+			name: "pipe row with missing cells",
+			report: "=== Top API Calls ===\n| Rank | Identifier | Timestamp | Duration (ms) |\n|---|---|---|---|\n" +
+				"| 1 | GE | 2026-02-03 10:00:00 | 500 |\n| 2 | SE | 300 |\n",
+			wantErrors: []domain.ParseRowError{
+				{Section: "Top API Calls", Line: "| 2 | SE | 300 |", Reason: ReasonColumnMismatch},
+			},
+			wantTables: []domain.TableCoverage{{Section: "Top API Calls", RowsPresent: 2, RowsParsed: 2}},
+		},
+		{
+			name: "pipe row with bad timestamp",
+			report: "=== Top API Calls ===\n| Rank | Identifier | Timestamp | Duration (ms) |\n" +
+				"| 1 | GE | 03/02/26 10h00 | 500 |\n",
+			wantErrors: []domain.ParseRowError{
+				{Section: "Top API Calls", Line: "| 1 | GE | 03/02/26 10h00 | 500 |", Reason: ReasonBadTimestamp},
+			},
+			wantTables: []domain.TableCoverage{{Section: "Top API Calls", RowsPresent: 1, RowsParsed: 1}},
+		},
+		{
+			name: "fixed-width rows",
+			report: "### API EXCEPTION REPORT\n\n" +
+				"   Line#    TrID Type Message\n" +
+				"-------- ------- ---- -------\n" +
+				"   16447  abc:01  SGE WARNING: no end\n" +
+				"   16448 abcdef:02 SE WARNING: no end\n",
+			wantErrors: []domain.ParseRowError{
+				{Section: "API EXCEPTION REPORT", Line: "16448 abcdef:02 SE WARNING: no end", Reason: ReasonColumnMismatch},
+			},
+			wantTables: []domain.TableCoverage{{Section: "API EXCEPTION REPORT", RowsPresent: 2, RowsParsed: 2}},
+		},
+		{
+			name: "fixed-width timestamp",
+			report: "### API THREAD STATISTICS BY QUEUE\n\n" +
+				"Queue          Thread            First Thread Time  Count\n" +
+				"---------- ---------- ---------------------------- ------\n" +
+				"Fast       0000000314 Mon Nov 24 2025 14:47:07.005      1\n" +
+				"           0000000316 yesterday at noon                 1\n",
+			wantErrors: []domain.ParseRowError{
+				{Section: "API THREAD STATISTICS BY QUEUE", Line: "0000000316 yesterday at noon                 1", Reason: ReasonBadTimestamp},
+			},
+			wantTables: []domain.TableCoverage{{Section: "API THREAD STATISTICS BY QUEUE", RowsPresent: 2, RowsParsed: 2}},
+		},
+		{
+			name: "subtotals are not rows",
+			report: "### SQL CALL AGGREGATES grouped by Table sorted by descending AVG execution time\n\n" +
+				"Table   SQL        OK  Total\n" +
+				"------- ------ ------ ------\n" +
+				"T18     INSERT      1      1\n" +
+				"        SELECT      2      2\n" +
+				"               ------ ------\n" +
+				"                    3      3\n\n" +
+				"               ====== ======\n" +
+				"                    3      3\n",
+			wantTables: []domain.TableCoverage{{
+				Section:     "SQL CALL AGGREGATES grouped by Table sorted by descending AVG execution time",
+				RowsPresent: 2, RowsParsed: 2,
+			}},
+		},
+		{
+			name: "whitespace rows",
+			report: "=== Top SQL Statements ===\nRank  Line#  Timestamp  Identifier  Duration\n" +
+				"1     100    2026-02-03 10:00:00.000    T001    Fast    SELECT    HPD:Help Desk    Demo    250    OK\n" +
+				"2     101    sometime    T002    Fast    SELECT    250    OK\n" +
+				"3     102    250\n",
+			wantErrors: []domain.ParseRowError{
+				{Section: "Top SQL Statements", Line: "2     101    sometime    T002    Fast    SELECT    250    OK", Reason: ReasonBadTimestamp},
+				{Section: "Top SQL Statements", Line: "3     102    250", Reason: ReasonColumnMismatch},
+			},
+			wantTables: []domain.TableCoverage{{Section: "Top SQL Statements", RowsPresent: 3, RowsParsed: 2}},
+		},
+		{
+			name:   "no data sentence",
+			report: "### 50 LONGEST QUEUED INDIVIDUAL API CALLS\n\nNo Queued API's\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseOutputWithOptions(tc.report, ParseOptions{Strict: true})
+			require.NoError(t, err)
+			d := result.Diagnostics
+			require.NotNil(t, d)
+			assert.Equal(t, tc.wantSkipped, d.SkippedSections)
+			assert.Equal(t, tc.wantErrors, d.RowErrors)
+			assert.Equal(t, tc.wantTables, d.Tables)
+			assert.Equal(t, d.SectionsSeen-len(tc.wantSkipped), d.SectionsRecognized)
+		})
+	}
+}
+
+func TestParseOutput_StrictCapsRowErrors(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("=== User Distribution ===\n")
+	b.WriteString(strings.Repeat("x", 2*maxRowErrorLineBytes) + "\n")
+	for i := 0; i < maxRowErrors+4; i++ {
+		b.WriteString("no separator here\n")
+	}
+	b.WriteString("Demo: 3\n")
+
+	result, err := ParseOutputWithOptions(b.String(), ParseOptions{Strict: true})
+	require.NoError(t, err)
+	d := result.Diagnostics
+	assert.Len(t, d.RowErrors, maxRowErrors)
+	assert.Len(t, d.RowErrors[0].Line, maxRowErrorLineBytes)
+	assert.Equal(t, 5, d.RowErrorsDropped)
+	assert.Equal(t, maxRowErrors+6, d.RowsPresent)
+	assert.Equal(t, 1, d.RowsParsed)
+}
@@ -0,0 +1,781 @@
+package jar
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// The structured report is what ARLogAnalyzer.jar writes with "-of json" or
+// "-of xml" instead of the fixed-width text report. Both encodings carry the
+// same document: JSON uses the field names below, XML uses one element per
+// object with its scalar fields as attributes and its lists as child
+// elements, e.g.
+//
+//	<arLogAnalyzer>
+//	  <generalStatistics startTime="2025-11-24T14:46:58.505" apiCount="251" .../>
+//	  <api>
+//	    <longestRunning sortedBy="execution time" sortOrder="descending">
+//	      <call runTime="0.122" line="8620" trid="..." api="SE" .../>
+//	    </longestRunning>
+//	    <aggregates groupedBy="Form" sortedBy="AVG execution time" sortOrder="descending">
+//	      <group name="SRM:Request"><row operation="SE" ok="1" .../><subtotal .../></group>
+//	      <total .../>
+//	    </aggregates>
+//	  </api>
+//	</arLogAnalyzer>
+//
+// Times are seconds and timestamps are local times without a zone, like in
+// the text report.
+
+type structuredReport struct {
+	XMLName    xml.Name             `json:"-" xml:"arLogAnalyzer"`
+	General    *structuredGeneral   `json:"generalStatistics" xml:"generalStatistics"`
+	Gaps       *structuredGaps      `json:"gaps" xml:"gaps"`
+	API        *structuredAPI       `json:"api" xml:"api"`
+	SQL        *structuredSQL       `json:"sql" xml:"sql"`
+	Escalation *structuredEsc       `json:"escalation" xml:"escalation"`
+	Filter     *structuredFilter    `json:"filter" xml:"filter"`
+	Activity   []structuredActivity `json:"loggingActivity" xml:"loggingActivity>logType"`
+	Files      []structuredFile     `json:"files" xml:"files>file"`
+}
+
+type structuredGeneral struct {
+	StartTime   string   `json:"startTime" xml:"startTime,attr"`
+	EndTime     string   `json:"endTime" xml:"endTime,attr"`
+	ElapsedTime *float64 `json:"elapsedTime" xml:"elapsedTime,attr"`
+	TotalLines  int64    `json:"totalLines" xml:"totalLines,attr"`
+	APICount    int64    `json:"apiCount" xml:"apiCount,attr"`
+	SQLCount    int64    `json:"sqlCount" xml:"sqlCount,attr"`
+	FilterCount int64    `json:"filterCount" xml:"filterCount,attr"`
+	EscCount    int64    `json:"escCount" xml:"escCount,attr"`
+	UserCount   int      `json:"userCount" xml:"userCount,attr"`
+	FormCount   int      `json:"formCount" xml:"formCount,attr"`
+	TableCount  int      `json:"tableCount" xml:"tableCount,attr"`
+
+	ThreadCounts []structuredThreadCount `json:"threadCounts" xml:"threadCounts>queue"`
+}
+
+type structuredThreadCount struct {
+	Queue   string `json:"queue" xml:"name,attr"`
+	Threads int    `json:"threads" xml:"threads,attr"`
+}
+
+type structuredGaps struct {
+	LineGaps   []structuredGap `json:"lineGaps" xml:"lineGaps>gap"`
+	ThreadGaps []structuredGap `json:"threadGaps" xml:"threadGaps>gap"`
+}
+
+type structuredGap struct {
+	Gap     float64 `json:"gap" xml:"gap,attr"`
+	Line    int     `json:"line" xml:"line,attr"`
+	TrID    string  `json:"trid" xml:"trid,attr"`
+	Time    string  `json:"time" xml:"time,attr"`
+	Details string  `json:"details" xml:"details,attr"`
+}
+
+type structuredAPI struct {
+	Abbreviations []structuredAbbreviation `json:"abbreviations" xml:"abbreviations>abbreviation"`
+	Longest       *structuredCalls         `json:"longestRunning" xml:"longestRunning"`
+	Queued        *structuredCalls         `json:"longestQueued" xml:"longestQueued"`
+	Aggregates    []structuredAggregates   `json:"aggregates" xml:"aggregates"`
+	Threads       []structuredThread       `json:"threads" xml:"threads>thread"`
+	Errors        []structuredAPIError     `json:"errors" xml:"errors>error"`
+	Exceptions    []structuredException    `json:"exceptions" xml:"exceptions>exception"`
+}
+
+type structuredSQL struct {
+	Longest    *structuredCalls       `json:"longestRunning" xml:"longestRunning"`
+	Aggregates []structuredAggregates `json:"aggregates" xml:"aggregates"`
+	Threads    []structuredThread     `json:"threads" xml:"threads>thread"`
+	Exceptions []structuredException  `json:"exceptions" xml:"exceptions>exception"`
+}
+
+type structuredEsc struct {
+	Longest    *structuredCalls       `json:"longestRunning" xml:"longestRunning"`
+	Aggregates []structuredAggregates `json:"aggregates" xml:"aggregates"`
+}
+
+type structuredFilter struct {
+	Longest        *structuredCalls        `json:"longestRunning" xml:"longestRunning"`
+	MostExecuted   []structuredFilterCount `json:"mostExecuted" xml:"mostExecuted>filter"`
+	PerTransaction []structuredFilterTxn   `json:"mostPerTransaction" xml:"mostPerTransaction>transaction"`
+	ExecutedPerTxn []structuredFilterCount `json:"mostExecutedPerTransaction" xml:"mostExecutedPerTransaction>filter"`
+	Levels         []structuredFilterTxn   `json:"mostLevels" xml:"mostLevels>transaction"`
+}
+
+type structuredAbbreviation struct {
+	Code string `json:"code" xml:"code,attr"`
+	Name string `json:"name" xml:"name,attr"`
+}
+
+// structuredCalls is a top-N table of API, SQL, escalation or filter calls.
+type structuredCalls struct {
+	SortedBy  string           `json:"sortedBy" xml:"sortedBy,attr"`
+	SortOrder string           `json:"sortOrder" xml:"sortOrder,attr"`
+	Calls     []structuredCall `json:"calls" xml:"call"`
+}
+
+// structuredCall is one call of a top-N table. Which of API, SQLStatement,
+// Escalation and Filter is set depends on the table.
+type structuredCall struct {
+	RunTime      float64 `json:"runTime" xml:"runTime,attr"`
+	Line         int     `json:"line" xml:"line,attr"`
+	LastLine     int     `json:"lastLine" xml:"lastLine,attr"`
+	File         int     `json:"file" xml:"file,attr"`
+	TrID         string  `json:"trid" xml:"trid,attr"`
+	RPCID        string  `json:"rpcId" xml:"rpcId,attr"`
+	Queue        string  `json:"queue" xml:"queue,attr"`
+	Pool         string  `json:"pool" xml:"pool,attr"`
+	API          string  `json:"api" xml:"api,attr"`
+	SQLStatement string  `json:"sqlStatement" xml:"sqlStatement,attr"`
+	Escalation   string  `json:"escalation" xml:"escalation,attr"`
+	Filter       string  `json:"filter" xml:"filter,attr"`
+	Table        string  `json:"table" xml:"table,attr"`
+	Form         string  `json:"form" xml:"form,attr"`
+	User         string  `json:"user" xml:"user,attr"`
+	StartTime    string  `json:"startTime" xml:"startTime,attr"`
+	QueueTime    float64 `json:"queueTime" xml:"queueTime,attr"`
+	Success      bool    `json:"success" xml:"success,attr"`
+	Error        string  `json:"error" xml:"error,attr"`
+}
+
+type structuredAggregates struct {
+	GroupedBy string                  `json:"groupedBy" xml:"groupedBy,attr"`
+	SortedBy  string                  `json:"sortedBy" xml:"sortedBy,attr"`
+	SortOrder string                  `json:"sortOrder" xml:"sortOrder,attr"`
+	Groups    []structuredAggGroup    `json:"groups" xml:"group"`
+	Total     *structuredAggregateRow `json:"total" xml:"total"`
+}
+
+type structuredAggGroup struct {
+	Name     string                   `json:"name" xml:"name,attr"`
+	Rows     []structuredAggregateRow `json:"rows" xml:"row"`
+	Subtotal *structuredAggregateRow  `json:"subtotal" xml:"subtotal"`
+}
+
+// structuredAggregateRow is one row of an aggregate table. Escalation
+// tables have Count instead of OK, Fail and Total.
+type structuredAggregateRow struct {
+	Operation string  `json:"operation" xml:"operation,attr"`
+	OK        int     `json:"ok" xml:"ok,attr"`
+	Fail      int     `json:"fail" xml:"fail,attr"`
+	Total     int     `json:"total" xml:"total,attr"`
+	Count     int     `json:"count" xml:"count,attr"`
+	MinTime   float64 `json:"minTime" xml:"minTime,attr"`
+	MinLine   int     `json:"minLine" xml:"minLine,attr"`
+	MaxTime   float64 `json:"maxTime" xml:"maxTime,attr"`
+	MaxLine   int     `json:"maxLine" xml:"maxLine,attr"`
+	AvgTime   float64 `json:"avgTime" xml:"avgTime,attr"`
+	SumTime   float64 `json:"sumTime" xml:"sumTime,attr"`
+}
+
+type structuredThread struct {
+	Queue     string  `json:"queue" xml:"queue,attr"`
+	Thread    string  `json:"thread" xml:"thread,attr"`
+	FirstTime string  `json:"firstTime" xml:"firstTime,attr"`
+	LastTime  string  `json:"lastTime" xml:"lastTime,attr"`
+	Count     int     `json:"count" xml:"count,attr"`
+	QCount    int     `json:"qCount" xml:"qCount,attr"`
+	QTime     float64 `json:"qTime" xml:"qTime,attr"`
+	TotalTime float64 `json:"totalTime" xml:"totalTime,attr"`
+	BusyPct   float64 `json:"busyPct" xml:"busyPct,attr"`
+}
+
+type structuredAPIError struct {
+	EndLine   int    `json:"endLine" xml:"endLine,attr"`
+	TrID      string `json:"trid" xml:"trid,attr"`
+	Queue     string `json:"queue" xml:"queue,attr"`
+	API       string `json:"api" xml:"api,attr"`
+	Form      string `json:"form" xml:"form,attr"`
+	User      string `json:"user" xml:"user,attr"`
+	StartTime string `json:"startTime" xml:"startTime,attr"`
+	Message   string `json:"message" xml:"message,attr"`
+}
+
+type structuredException struct {
+	Line         int    `json:"line" xml:"line,attr"`
+	TrID         string `json:"trid" xml:"trid,attr"`
+	Type         string `json:"type" xml:"type,attr"`
+	Message      string `json:"message" xml:"message,attr"`
+	SQLStatement string `json:"sqlStatement" xml:"sqlStatement,attr"`
+}
+
+// structuredFilterCount is a filter with its pass and fail counts, overall
+// or, when Line and TrID are set, within one transaction.
+type structuredFilterCount struct {
+	Line   int    `json:"line" xml:"line,attr"`
+	TrID   string `json:"trid" xml:"trid,attr"`
+	Filter string `json:"filter" xml:"filter,attr"`
+	Pass   int    `json:"pass" xml:"pass,attr"`
+	Fail   int    `json:"fail" xml:"fail,attr"`
+}
+
+// structuredFilterTxn is a transaction of the most-filters and most-levels
+// tables.
+type structuredFilterTxn struct {
+	Line          int     `json:"line" xml:"line,attr"`
+	TrID          string  `json:"trid" xml:"trid,attr"`
+	FilterCount   int     `json:"filterCount" xml:"filterCount,attr"`
+	Level         int     `json:"level" xml:"level,attr"`
+	Operation     string  `json:"operation" xml:"operation,attr"`
+	Form          string  `json:"form" xml:"form,attr"`
+	RequestID     string  `json:"requestId" xml:"requestId,attr"`
+	FiltersPerSec float64 `json:"filtersPerSec" xml:"filtersPerSec,attr"`
+}
+
+type structuredActivity struct {
+	Type     string  `json:"type" xml:"type,attr"`
+	First    string  `json:"first" xml:"first,attr"`
+	Last     string  `json:"last" xml:"last,attr"`
+	Duration float64 `json:"duration" xml:"duration,attr"`
+	Count    int     `json:"count" xml:"count,attr"`
+}
+
+type structuredFile struct {
+	Number   int     `json:"number" xml:"number,attr"`
+	Name     string  `json:"name" xml:"name,attr"`
+	Start    string  `json:"start" xml:"start,attr"`
+	End      string  `json:"end" xml:"end,attr"`
+	Duration float64 `json:"duration" xml:"duration,attr"`
+	Count    int     `json:"count" xml:"count,attr"`
+}
+
+// ParseStructuredOutput parses a structured report written by
+// ARLogAnalyzer.jar with "-of xml" or "-of json" into the same ParseResult
+// ParseOutput builds from the text report. format is domain.JAROutputXML or
+// domain.JAROutputJSON.
+//
+// The structured report has no fixed-width columns to get wrong, so strict
+// parse diagnostics are not collected for it.
+func ParseStructuredOutput(r io.Reader, format string) (*domain.ParseResult, error) {
+	var doc structuredReport
+	var err error
+	switch format {
+	case domain.JAROutputJSON:
+		err = json.NewDecoder(r).Decode(&doc)
+	case domain.JAROutputXML:
+		err = xml.NewDecoder(r).Decode(&doc)
+	default:
+		return nil, fmt.Errorf("jar parser: unsupported structured format %q", format)
+	}
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("jar parser: empty output")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jar parser: decode %s report: %w", format, err)
+	}
+
+	data := &domain.DashboardData{
+		Distribution: make(map[string]map[string]int),
+	}
+	result := &domain.ParseResult{Dashboard: data}
+
+	if g := doc.General; g != nil {
+		data.GeneralStats = g.stats()
+	}
+	if g := doc.Gaps; g != nil {
+		lineGaps, threadGaps := structuredGapEntries(g.LineGaps), structuredGapEntries(g.ThreadGaps)
+		if len(lineGaps) > 0 || len(threadGaps) > 0 {
+			result.JARGaps = &domain.JARGapsResponse{Source: "jar_parsed", LineGaps: lineGaps, ThreadGaps: threadGaps}
+		}
+	}
+
+	if a := doc.API; a != nil {
+		for _, abbr := range a.Abbreviations {
+			if abbr.Code != "" && abbr.Name != "" {
+				result.APIAbbreviations = append(result.APIAbbreviations, domain.JARAPIAbbreviation{
+					Abbreviation: abbr.Code,
+					FullName:     abbr.Name,
+				})
+			}
+		}
+		if a.Longest != nil {
+			data.TopAPICalls = a.Longest.entries()
+			setStructuredSort(data, "api", a.Longest, "execution time")
+		}
+		if a.Queued != nil && len(a.Queued.Calls) > 0 {
+			result.QueuedAPICalls = a.Queued.entries()
+			result.JARQueuedAPICalls = a.Queued.queuedCalls()
+			sort := a.Queued.sort("queue time")
+			result.QueuedAPICallsSort = &sort
+		}
+		for _, agg := range a.Aggregates {
+			switch strings.ToLower(agg.GroupedBy) {
+			case "form":
+				jarAggregates(result).APIByForm = agg.table()
+			case "client":
+				jarAggregates(result).APIByClient = agg.table()
+			case "client ip":
+				jarAggregates(result).APIByClientIP = agg.table()
+			}
+		}
+		if threads := structuredThreadStats(a.Threads); len(threads) > 0 {
+			jarThreadStats(result).APIThreads = threads
+		}
+		var errs []domain.JARAPIError
+		for _, e := range a.Errors {
+			if e.TrID == "" && e.EndLine <= 0 {
+				continue
+			}
+			errs = append(errs, domain.JARAPIError{
+				EndLine:      e.EndLine,
+				TraceID:      e.TrID,
+				Queue:        e.Queue,
+				API:          e.API,
+				Form:         e.Form,
+				User:         e.User,
+				StartTime:    parseStructuredTime(e.StartTime),
+				ErrorMessage: e.Message,
+			})
+		}
+		if len(errs) > 0 {
+			jarExceptions(result).APIErrors = errs
+		}
+		if exceptions := structuredExceptions(a.Exceptions); len(exceptions) > 0 {
+			jarExceptions(result).APIExceptions = exceptions
+		}
+	}
+
+	if s := doc.SQL; s != nil {
+		if s.Longest != nil {
+			data.TopSQL = s.Longest.entries()
+			setStructuredSort(data, "sql", s.Longest, "execution time")
+		}
+		for _, agg := range s.Aggregates {
+			if strings.EqualFold(agg.GroupedBy, "table") {
+				jarAggregates(result).SQLByTable = agg.table()
+			}
+		}
+		if threads := structuredThreadStats(s.Threads); len(threads) > 0 {
+			jarThreadStats(result).SQLThreads = threads
+		}
+		if exceptions := structuredExceptions(s.Exceptions); len(exceptions) > 0 {
+			jarExceptions(result).SQLExceptions = exceptions
+		}
+	}
+
+	if e := doc.Escalation; e != nil {
+		if e.Longest != nil {
+			data.TopEscalations = e.Longest.entries()
+			setStructuredSort(data, "escalations", e.Longest, "execution time")
+		}
+		for _, agg := range e.Aggregates {
+			switch strings.ToLower(agg.GroupedBy) {
+			case "form":
+				jarAggregates(result).EscByForm = agg.table()
+			case "pool":
+				jarAggregates(result).EscByPool = agg.table()
+			}
+		}
+	}
+
+	if f := doc.Filter; f != nil {
+		if f.Longest != nil {
+			data.TopFilters = f.Longest.entries()
+			setStructuredSort(data, "filters", f.Longest, "execution time")
+		}
+		parseStructuredFilters(result, f)
+	}
+
+	for _, a := range doc.Activity {
+		if a.Type == "" {
+			continue
+		}
+		result.LoggingActivities = append(result.LoggingActivities, domain.LoggingActivity{
+			LogType:        a.Type,
+			FirstTimestamp: parseStructuredTime(a.First),
+			LastTimestamp:  parseStructuredTime(a.Last),
+			DurationMS:     int64(domain.DurationMSFromSeconds(a.Duration)),
+			EntryCount:     a.Count,
+		})
+	}
+	for _, f := range doc.Files {
+		if f.Name == "" && f.Number <= 0 {
+			continue
+		}
+		result.FileMetadataList = append(result.FileMetadataList, domain.FileMetadata{
+			FileNumber: f.Number,
+			FileName:   f.Name,
+			StartTime:  parseStructuredTime(f.Start),
+			EndTime:    parseStructuredTime(f.End),
+			DurationMS: int64(domain.DurationMSFromSeconds(f.Duration)),
+			EntryCount: f.Count,
+		})
+	}
+
+	finishParseResult(result)
+	return result, nil
+}
+
+func (g *structuredGeneral) stats() domain.GeneralStatistics {
+	stats := domain.GeneralStatistics{
+		TotalLines:   g.TotalLines,
+		APICount:     g.APICount,
+		SQLCount:     g.SQLCount,
+		FilterCount:  g.FilterCount,
+		EscCount:     g.EscCount,
+		UniqueUsers:  g.UserCount,
+		UniqueForms:  g.FormCount,
+		UniqueTables: g.TableCount,
+		LogStart:     parseStructuredTime(g.StartTime),
+		LogEnd:       parseStructuredTime(g.EndTime),
+	}
+	if g.ElapsedTime != nil {
+		stats.LogDuration = strconv.FormatFloat(*g.ElapsedTime, 'f', -1, 64)
+		stats.LogDurationMS = domain.DurationMSFromSeconds(*g.ElapsedTime)
+	}
+	for _, tc := range g.ThreadCounts {
+		if tc.Queue == "" {
+			continue
+		}
+		if stats.ThreadCounts == nil {
+			stats.ThreadCounts = make(map[string]int, len(g.ThreadCounts))
+		}
+		stats.ThreadCounts[tc.Queue] = tc.Threads
+	}
+	return stats
+}
+
+// entries converts the calls to ranked top-N entries, mapping the columns
+// as mapFixedWidthToEntry does for the text report.
+func (c *structuredCalls) entries() []domain.TopNEntry {
+	var entries []domain.TopNEntry
+	for _, call := range c.Calls {
+		entry := domain.TopNEntry{
+			LineNumber:  call.Line,
+			FileNumber:  call.File,
+			Timestamp:   parseStructuredTime(call.StartTime),
+			TraceID:     call.TrID,
+			RPCID:       call.RPCID,
+			Queue:       call.Queue,
+			Form:        call.Form,
+			User:        call.User,
+			DurationMS:  secondsToMS(call.RunTime),
+			QueueTimeMS: secondsToMS(call.QueueTime),
+			Success:     call.Success,
+		}
+		for _, id := range []string{call.API, call.SQLStatement, call.Escalation, call.Filter} {
+			if id != "" {
+				entry.Identifier = id
+				break
+			}
+		}
+		if call.Table != "" {
+			entry.Form = call.Table
+		}
+		if call.Pool != "" {
+			entry.Queue = call.Pool
+		}
+		if call.LastLine > 0 {
+			entry.Details = "last_line=" + strconv.Itoa(call.LastLine)
+		}
+		if call.Error != "" {
+			entry.Details = call.Error
+		}
+		if entry.Identifier != "" || entry.DurationMS > 0 || entry.LineNumber > 0 {
+			entry.Rank = len(entries) + 1
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// queuedCalls maps the longest queued API calls to their queue wait and
+// run time, as parseQueuedAPICalls does for the text report.
+func (c *structuredCalls) queuedCalls() []domain.JARQueuedAPICall {
+	var calls []domain.JARQueuedAPICall
+	for _, call := range c.Calls {
+		q := domain.JARQueuedAPICall{
+			LineNumber: call.Line,
+			TraceID:    call.TrID,
+			RPCID:      call.RPCID,
+			Queue:      call.Queue,
+			API:        call.API,
+			Form:       call.Form,
+			User:       call.User,
+			Dispatch:   parseStructuredTime(call.StartTime),
+			WaitMS:     secondsToMS(call.QueueTime),
+			RunMS:      secondsToMS(call.RunTime),
+			Success:    call.Success,
+		}
+		q.TotalMS = q.WaitMS + q.RunMS
+		if !q.Dispatch.IsZero() {
+			q.WaitStart = domain.NewTimestamp(q.Dispatch.Add(-time.Duration(q.WaitMS) * time.Millisecond))
+		}
+		if q.API != "" || q.TotalMS > 0 || q.LineNumber > 0 {
+			q.Rank = len(calls) + 1
+			calls = append(calls, q)
+		}
+	}
+	return calls
+}
+
+// sort returns the ordering the JAR states for the table, or defaultBy
+// descending as topNSort assumes for text reports.
+func (c *structuredCalls) sort(defaultBy string) domain.TableSort {
+	if c.SortedBy == "" {
+		return domain.TableSort{SortedBy: defaultBy, SortDirection: domain.SortDescending}
+	}
+	s := domain.TableSort{SortedBy: c.SortedBy}
+	if c.SortOrder != "" {
+		s.SortDirection = sortDirection(c.SortOrder)
+	}
+	return s
+}
+
+func setStructuredSort(data *domain.DashboardData, key string, c *structuredCalls, defaultBy string) {
+	if data.TopNSort == nil {
+		data.TopNSort = make(map[string]domain.TableSort)
+	}
+	data.TopNSort[key] = c.sort(defaultBy)
+}
+
+func (a *structuredAggregates) table() *domain.JARAggregateTable {
+	table := &domain.JARAggregateTable{
+		GroupedBy: a.GroupedBy,
+		SortedBy:  a.SortedBy,
+	}
+	if a.SortOrder != "" {
+		table.SortDirection = sortDirection(a.SortOrder)
+	}
+	for _, g := range a.Groups {
+		group := domain.JARAggregateGroup{EntityName: g.Name}
+		for _, r := range g.Rows {
+			row := r.row()
+			if row.Total > 0 || row.OK > 0 || row.OperationType != "" {
+				group.Rows = append(group.Rows, row)
+			}
+		}
+		if g.Subtotal != nil {
+			row := g.Subtotal.row()
+			group.Subtotal = &row
+		}
+		table.Groups = append(table.Groups, group)
+	}
+	if a.Total != nil {
+		row := a.Total.row()
+		table.GrandTotal = &row
+	}
+	return table
+}
+
+func (r *structuredAggregateRow) row() domain.JARAggregateRow {
+	row := domain.JARAggregateRow{
+		OperationType: r.Operation,
+		OK:            r.OK,
+		Fail:          r.Fail,
+		Total:         r.Total,
+		MinTime:       r.MinTime,
+		MinLine:       r.MinLine,
+		MaxTime:       r.MaxTime,
+		MaxLine:       r.MaxLine,
+		AvgTime:       r.AvgTime,
+		SumTime:       r.SumTime,
+		MinTimeMS:     domain.DurationMSFromSeconds(r.MinTime),
+		MaxTimeMS:     domain.DurationMSFromSeconds(r.MaxTime),
+		AvgTimeMS:     domain.DurationMSFromSeconds(r.AvgTime),
+		SumTimeMS:     domain.DurationMSFromSeconds(r.SumTime),
+	}
+	if row.Total == 0 {
+		row.Total = r.Count
+	}
+	return row
+}
+
+func structuredGapEntries(gaps []structuredGap) []domain.JARGapEntry {
+	var entries []domain.JARGapEntry
+	for _, g := range gaps {
+		if g.Gap <= 0 && g.TrID == "" {
+			continue
+		}
+		entries = append(entries, domain.JARGapEntry{
+			GapDuration:   g.Gap,
+			GapDurationMS: domain.DurationMSFromSeconds(g.Gap),
+			LineNumber:    g.Line,
+			TraceID:       g.TrID,
+			Timestamp:     parseStructuredTime(g.Time),
+			Details:       g.Details,
+		})
+	}
+	return entries
+}
+
+func structuredThreadStats(threads []structuredThread) []domain.JARThreadStat {
+	var stats []domain.JARThreadStat
+	for _, t := range threads {
+		if t.Thread == "" {
+			continue
+		}
+		stats = append(stats, domain.JARThreadStat{
+			Queue:       t.Queue,
+			ThreadID:    t.Thread,
+			FirstTime:   parseStructuredTime(t.FirstTime),
+			LastTime:    parseStructuredTime(t.LastTime),
+			Count:       t.Count,
+			QCount:      t.QCount,
+			QTime:       t.QTime,
+			TotalTime:   t.TotalTime,
+			BusyPct:     t.BusyPct,
+			QTimeMS:     domain.DurationMSFromSeconds(t.QTime),
+			TotalTimeMS: domain.DurationMSFromSeconds(t.TotalTime),
+		})
+	}
+	return stats
+}
+
+func structuredExceptions(exceptions []structuredException) []domain.JARExceptionEntry {
+	var entries []domain.JARExceptionEntry
+	for _, e := range exceptions {
+		if e.Line <= 0 && e.TrID == "" {
+			continue
+		}
+		entries = append(entries, domain.JARExceptionEntry{
+			LineNumber:   e.Line,
+			TraceID:      e.TrID,
+			Type:         e.Type,
+			Message:      e.Message,
+			SQLStatement: e.SQLStatement,
+		})
+	}
+	return entries
+}
+
+func parseStructuredFilters(result *domain.ParseResult, f *structuredFilter) {
+	filters := func() *domain.JARFilterComplexityResponse {
+		if result.JARFilters == nil {
+			result.JARFilters = &domain.JARFilterComplexityResponse{Source: "jar_parsed"}
+		}
+		return result.JARFilters
+	}
+
+	var mostExecuted []domain.JARFilterMostExecuted
+	for _, e := range f.MostExecuted {
+		if e.Filter != "" {
+			mostExecuted = append(mostExecuted, domain.JARFilterMostExecuted{FilterName: e.Filter, PassCount: e.Pass, FailCount: e.Fail})
+		}
+	}
+	if len(mostExecuted) > 0 {
+		filters().MostExecuted = mostExecuted
+	}
+
+	var perTxn []domain.JARFilterPerTransaction
+	for _, t := range f.PerTransaction {
+		if t.Line <= 0 && t.TrID == "" {
+			continue
+		}
+		perTxn = append(perTxn, domain.JARFilterPerTransaction{
+			LineNumber:    t.Line,
+			TraceID:       t.TrID,
+			FilterCount:   t.FilterCount,
+			Operation:     t.Operation,
+			Form:          t.Form,
+			RequestID:     t.RequestID,
+			FiltersPerSec: finite(t.FiltersPerSec),
+		})
+	}
+	if len(perTxn) > 0 {
+		filters().PerTransaction = perTxn
+	}
+
+	var executedPerTxn []domain.JARFilterExecutedPerTxn
+	for _, e := range f.ExecutedPerTxn {
+		if e.Line <= 0 && e.TrID == "" {
+			continue
+		}
+		executedPerTxn = append(executedPerTxn, domain.JARFilterExecutedPerTxn{
+			LineNumber: e.Line,
+			TraceID:    e.TrID,
+			FilterName: e.Filter,
+			PassCount:  e.Pass,
+			FailCount:  e.Fail,
+		})
+	}
+	if len(executedPerTxn) > 0 {
+		filters().ExecutedPerTxn = executedPerTxn
+	}
+
+	var levels []domain.JARFilterLevel
+	for _, t := range f.Levels {
+		if t.Line <= 0 && t.TrID == "" {
+			continue
+		}
+		levels = append(levels, domain.JARFilterLevel{
+			LineNumber:  t.Line,
+			TraceID:     t.TrID,
+			FilterLevel: t.Level,
+			Operation:   t.Operation,
+			Form:        t.Form,
+			RequestID:   t.RequestID,
+		})
+	}
+	if len(levels) > 0 {
+		filters().FilterLevels = levels
+	}
+}
+
+// structuredTimestampLayouts are tried before the text report layouts.
+var structuredTimestampLayouts = []string{
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+}
+
+func parseStructuredTime(s string) domain.Timestamp {
+	s = strings.TrimSpace(s)
+	for _, layout := range structuredTimestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return domain.NewTimestamp(t)
+		}
+	}
+	return parseTimestampSafe(s)
+}
+
+// secondsToMS rounds like parseFloatSecondsToMS.
+func secondsToMS(s float64) int {
+	return int(finite(s)*1000 + 0.5)
+}
+
+// finite maps NaN and infinities, which the JAR writes for rates over
+// empty intervals, to zero.
+func finite(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return f
+}
+
+// IsStructuredOutput reports whether output starts like a report in format.
+// JARs that do not know the -of flag may ignore it and write the text
+// report instead.
+func IsStructuredOutput(output, format string) bool {
+	trimmed := strings.TrimLeft(output, " \t\r\n\ufeff")
+	switch format {
+	case domain.JAROutputXML:
+		return strings.HasPrefix(trimmed, "<")
+	case domain.JAROutputJSON:
+		return strings.HasPrefix(trimmed, "{")
+	}
+	return false
+}
+
+var unsupportedOptionRe = regexp.MustCompile(`(?i)(unknown|unrecognized|unrecognised|invalid|illegal|unsupported)\s+(command[- ]line\s+)?(option|argument|flag|parameter|switch)|\busage:`)
+
+// UnsupportedOutputFormat reports whether a failed run was refused because
+// the JAR does not know the -of flag, in which case the text report can
+// be requested instead.
+func UnsupportedOutputFormat(res *Result) bool {
+	if res == nil || res.ExitCode == 0 {
+		return false
+	}
+	return strings.Contains(res.Stderr, "-of") || unsupportedOptionRe.MatchString(res.Stderr)
+}
@@ -0,0 +1,204 @@
+package jar
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// The jar_output_log1 fixtures are the text, JSON and XML reports of the
+// same capture. Parsing any of them must give the same ParseResult, which
+// also guards the text parser against regressions.
+
+func parseStructuredFixture(t *testing.T, format string) *domain.ParseResult {
+	t.Helper()
+	f, err := os.Open("../../testdata/jar_output_log1." + format)
+	require.NoError(t, err)
+	defer f.Close()
+
+	result, err := ParseStructuredOutput(f, format)
+	require.NoError(t, err)
+	return result
+}
+
+func parseTextFixture(t *testing.T) *domain.ParseResult {
+	t.Helper()
+	content, err := os.ReadFile("../../testdata/jar_output_log1.txt")
+	require.NoError(t, err)
+	result, err := ParseOutput(string(content))
+	require.NoError(t, err)
+
+	// The SQL exception message is wider than its column in the text report
+	// and runs into the SQL statement column. The structured report has the
+	// two apart.
+	require.Len(t, result.JARExceptions.SQLExceptions, 1)
+	e := &result.JARExceptions.SQLExceptions[0]
+	require.Equal(t, "WARNING: Start of SQL c", e.Message, "text parser splits the message differently; update this fix-up")
+	e.Message = "WARNING: Start of SQL call has no corresponding end"
+	e.SQLStatement = strings.TrimPrefix(e.SQLStatement, "ll has no corresponding end ")
+	return result
+}
+
+func TestParseStructuredOutput_EquivalentToText(t *testing.T) {
+	want := parseTextFixture(t)
+
+	for _, format := range []string{domain.JAROutputJSON, domain.JAROutputXML} {
+		t.Run(format, func(t *testing.T) {
+			got := parseStructuredFixture(t, format)
+
+			// Compare section by section first so that a mismatch names it.
+			assert.Equal(t, want.Dashboard.GeneralStats, got.Dashboard.GeneralStats, "general statistics")
+			assert.Equal(t, want.Dashboard.TopAPICalls, got.Dashboard.TopAPICalls, "top API calls")
+			assert.Equal(t, want.Dashboard.TopSQL, got.Dashboard.TopSQL, "top SQL")
+			assert.Equal(t, want.Dashboard.TopFilters, got.Dashboard.TopFilters, "top filters")
+			assert.Equal(t, want.Dashboard.TopEscalations, got.Dashboard.TopEscalations, "top escalations")
+			assert.Equal(t, want.Dashboard.TopNSort, got.Dashboard.TopNSort, "top-N ordering")
+			assert.Equal(t, want.JARGaps, got.JARGaps, "gaps")
+			assert.Equal(t, want.JARAggregates, got.JARAggregates, "aggregates")
+			assert.Equal(t, want.JARExceptions, got.JARExceptions, "exceptions")
+			assert.Equal(t, want.JARThreadStats, got.JARThreadStats, "thread statistics")
+			assert.Equal(t, want.JARFilters, got.JARFilters, "filters")
+			assert.Equal(t, want.APIAbbreviations, got.APIAbbreviations, "abbreviations")
+			assert.Equal(t, want.QueuedAPICalls, got.QueuedAPICalls, "queued API calls")
+			assert.Equal(t, want.Sections, got.Sections, "section presence")
+
+			// The profile and banner version only describe text reports.
+			assert.Equal(t, "v4.0", want.Profile)
+			assert.Equal(t, withoutProfile(want), got)
+		})
+	}
+}
+
+func TestParseStructuredOutput_Fields(t *testing.T) {
+	got := parseStructuredFixture(t, domain.JAROutputJSON)
+	data := got.Dashboard
+
+	assert.Equal(t, "10.162", data.GeneralStats.LogDuration)
+	assert.Equal(t, domain.DurationMS(10162), data.GeneralStats.LogDurationMS)
+	assert.Equal(t, "2025-11-24T14:46:58.505Z", data.GeneralStats.LogStart.String())
+
+	require.NotEmpty(t, data.TopAPICalls)
+	first := data.TopAPICalls[0]
+	assert.Equal(t, 1, first.Rank)
+	assert.Equal(t, "SE", first.Identifier)
+	assert.Equal(t, 122, first.DurationMS)
+	assert.Equal(t, "last_line=10031", first.Details)
+	assert.True(t, first.Success)
+
+	require.Len(t, data.TopEscalations, 1)
+	assert.Equal(t, "6", data.TopEscalations[0].Queue, "the escalation pool is kept as the queue")
+
+	require.NotEmpty(t, data.TopSQL)
+	assert.Equal(t, "T4381", data.TopSQL[0].Form, "the SQL table is kept as the form")
+	assert.True(t, strings.HasPrefix(data.TopSQL[0].Identifier, "SELECT"))
+
+	pool := got.JARAggregates.EscByPool
+	require.NotNil(t, pool)
+	assert.Equal(t, 1, pool.Groups[0].Rows[0].Total, "escalation counts become totals")
+
+	// Rates the JAR cannot compute are NaN in XML and null in JSON.
+	for _, format := range []string{domain.JAROutputJSON, domain.JAROutputXML} {
+		r := parseStructuredFixture(t, format)
+		last := r.JARFilters.PerTransaction[len(r.JARFilters.PerTransaction)-1]
+		assert.Zero(t, last.FilterCount)
+		assert.Zero(t, last.FiltersPerSec, format)
+	}
+}
+
+func TestParseStructuredOutput_Minimal(t *testing.T) {
+	tests := map[string]string{
+		domain.JAROutputJSON: `{"generalStatistics": {"apiCount": 3, "startTime": "2026-02-03T10:00:00"},
+			"api": {"longestQueued": {"calls": [{"runTime": 1.5, "queueTime": 0.25, "line": 7, "api": "GE"}]}},
+			"files": [{"number": 1, "name": "arapi.log", "start": "Mon Feb 03 2026 10:00:00.000", "duration": 3600}],
+			"loggingActivity": [{"type": "API", "first": "2026-02-03T10:00:00", "duration": 90.5, "count": 3}]}`,
+		domain.JAROutputXML: `<arLogAnalyzer>
+			<generalStatistics apiCount="3" startTime="2026-02-03T10:00:00"/>
+			<api><longestQueued><call runTime="1.5" queueTime="0.25" line="7" api="GE"/></longestQueued></api>
+			<files><file number="1" name="arapi.log" start="Mon Feb 03 2026 10:00:00.000" duration="3600"/></files>
+			<loggingActivity><logType type="API" first="2026-02-03T10:00:00" duration="90.5" count="3"/></loggingActivity>
+			</arLogAnalyzer>`,
+	}
+	for format, report := range tests {
+		t.Run(format, func(t *testing.T) {
+			got, err := ParseStructuredOutput(strings.NewReader(report), format)
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(3), got.Dashboard.GeneralStats.APICount)
+			assert.Empty(t, got.Dashboard.GeneralStats.LogDuration, "no elapsed time stated")
+			assert.Nil(t, got.Dashboard.TopAPICalls)
+			assert.NotNil(t, got.Dashboard.Distribution)
+
+			require.Len(t, got.QueuedAPICalls, 1)
+			assert.Equal(t, 1500, got.QueuedAPICalls[0].DurationMS)
+			assert.Equal(t, 250, got.QueuedAPICalls[0].QueueTimeMS)
+			assert.Equal(t, &domain.TableSort{SortedBy: "queue time", SortDirection: domain.SortDescending}, got.QueuedAPICallsSort)
+			require.Len(t, got.JARQueuedAPICalls, 1)
+			assert.Equal(t, domain.JARQueuedAPICall{Rank: 1, LineNumber: 7, API: "GE", WaitMS: 250, RunMS: 1500, TotalMS: 1750}, got.JARQueuedAPICalls[0])
+
+			require.Len(t, got.FileMetadataList, 1)
+			assert.Equal(t, "arapi.log", got.FileMetadataList[0].FileName)
+			assert.Equal(t, int64(3600000), got.FileMetadataList[0].DurationMS)
+			assert.False(t, got.FileMetadataList[0].StartTime.IsZero(), "text report timestamps are accepted too")
+
+			require.Len(t, got.LoggingActivities, 1)
+			assert.Equal(t, int64(90500), got.LoggingActivities[0].DurationMS)
+			assert.Equal(t, 3, got.LoggingActivities[0].EntryCount)
+
+			require.NotNil(t, got.Sections)
+			assert.True(t, got.Sections.API.Present)
+			assert.False(t, got.Sections.SQL.Present)
+		})
+	}
+}
+
+func TestParseStructuredOutput_Errors(t *testing.T) {
+	tests := []struct {
+		name, format, report, wantErr string
+	}{
+		{"empty json", domain.JAROutputJSON, "", "empty output"},
+		{"empty xml", domain.JAROutputXML, "  \n", "empty output"},
+		{"text report", domain.JAROutputJSON, "=== General Statistics ===\n", "decode json report"},
+		{"wrong root", domain.JAROutputXML, "<report/>", "decode xml report"},
+		{"text format", domain.JAROutputText, "{}", "unsupported structured format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseStructuredOutput(strings.NewReader(tt.report), tt.format)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestIsStructuredOutput(t *testing.T) {
+	assert.True(t, IsStructuredOutput("\n  <?xml version=\"1.0\"?><arLogAnalyzer/>", domain.JAROutputXML))
+	assert.True(t, IsStructuredOutput("{\"api\": {}}", domain.JAROutputJSON))
+	assert.False(t, IsStructuredOutput("AR System Log Analyzer, version 3.2.2\n", domain.JAROutputJSON))
+	assert.False(t, IsStructuredOutput("{}", domain.JAROutputXML))
+	assert.False(t, IsStructuredOutput("{}", domain.JAROutputText))
+}
+
+func TestUnsupportedOutputFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		res  *Result
+		want bool
+	}{
+		{"nil result", nil, false},
+		{"success", &Result{Stderr: "Unknown option -of"}, false},
+		{"unknown option", &Result{ExitCode: 1, Stderr: "Unknown option: -of"}, true},
+		{"usage", &Result{ExitCode: 2, Stderr: "Usage: ARLogAnalyzer [options] files"}, true},
+		{"invalid argument", &Result{ExitCode: 1, Stderr: "Invalid argument json"}, true},
+		{"out of memory", &Result{ExitCode: 1, Stderr: "java.lang.OutOfMemoryError: Java heap space"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, UnsupportedOutputFormat(tt.res))
+		})
+	}
+}
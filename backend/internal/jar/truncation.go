@@ -0,0 +1,167 @@
+package jar
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// TruncationMarker ends the names the JAR cut to the width of their report
+// column.
+const TruncationMarker = "`!"
+
+// maxNameCandidates caps the candidates listed for an ambiguous name.
+const maxNameCandidates = 10
+
+// cutTruncation strips the truncation marker from name, returning the flag
+// of a name that carried one and nil otherwise. Names flagged already, and
+// names only ending in punctuation, are left alone.
+func cutTruncation(name *string, flag *domain.TruncatedName) *domain.TruncatedName {
+	if flag != nil {
+		return flag
+	}
+	prefix, ok := strings.CutSuffix(*name, TruncationMarker)
+	if !ok || prefix == "" {
+		return nil
+	}
+	*name = prefix
+	return &domain.TruncatedName{Truncated: prefix, Confidence: domain.NameUnresolved}
+}
+
+// markTruncatedNames flags the truncated names of the filter sections,
+// stripping their markers.
+func markTruncatedNames(f *domain.JARFilterComplexityResponse) {
+	for i := range f.MostExecuted {
+		e := &f.MostExecuted[i]
+		e.FilterNameTruncated = cutTruncation(&e.FilterName, e.FilterNameTruncated)
+	}
+	for i := range f.ExecutedPerTxn {
+		e := &f.ExecutedPerTxn[i]
+		e.FilterNameTruncated = cutTruncation(&e.FilterName, e.FilterNameTruncated)
+	}
+	for i := range f.PerTransaction {
+		e := &f.PerTransaction[i]
+		e.FormTruncated = cutTruncation(&e.Form, e.FormTruncated)
+	}
+	for i := range f.FilterLevels {
+		e := &f.FilterLevels[i]
+		e.FormTruncated = cutTruncation(&e.Form, e.FormTruncated)
+	}
+}
+
+// ResolvePrefix recovers the full name of a name the JAR truncated to
+// prefix from the complete names known for its job. Exactly one known name
+// starting with prefix is the full name. Several leave the name ambiguous,
+// and none leave it unresolved: name is then prefix itself, and an
+// ambiguous resolution lists the candidates.
+func ResolvePrefix(prefix string, known []string) (name string, resolution domain.TruncatedName) {
+	resolution = domain.TruncatedName{Truncated: prefix, Confidence: domain.NameUnresolved}
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, k := range known {
+		if seen[k] || !strings.HasPrefix(k, prefix) || strings.HasSuffix(k, TruncationMarker) {
+			continue
+		}
+		seen[k] = true
+		candidates = append(candidates, k)
+	}
+	switch len(candidates) {
+	case 0:
+		return prefix, resolution
+	case 1:
+		resolution.Confidence = domain.NameResolved
+		return candidates[0], resolution
+	}
+	sort.Strings(candidates)
+	resolution.Confidence = domain.NameAmbiguous
+	resolution.Candidates = candidates[:min(len(candidates), maxNameCandidates)]
+	return prefix, resolution
+}
+
+// ResolveTruncatedNames flags the truncated names of the filter sections
+// and recovers their full names from the complete names of the sections
+// and from those given, such as the distinct values stored for the job.
+// Names resolved already are kept. It returns how many names it resolved.
+func ResolveTruncatedNames(f *domain.JARFilterComplexityResponse, filterNames, forms []string) int {
+	if f == nil {
+		return 0
+	}
+	markTruncatedNames(f)
+
+	knownFilters := append([]string(nil), filterNames...)
+	for _, e := range f.LongestRunning {
+		knownFilters = append(knownFilters, e.Identifier)
+	}
+	for _, e := range f.MostExecuted {
+		if e.FilterNameTruncated == nil {
+			knownFilters = append(knownFilters, e.FilterName)
+		}
+	}
+	for _, e := range f.ExecutedPerTxn {
+		if e.FilterNameTruncated == nil {
+			knownFilters = append(knownFilters, e.FilterName)
+		}
+	}
+	knownForms := append([]string(nil), forms...)
+	for _, e := range f.LongestRunning {
+		knownForms = append(knownForms, e.Form)
+	}
+	for _, e := range f.PerTransaction {
+		if e.FormTruncated == nil {
+			knownForms = append(knownForms, e.Form)
+		}
+	}
+	for _, e := range f.FilterLevels {
+		if e.FormTruncated == nil {
+			knownForms = append(knownForms, e.Form)
+		}
+	}
+
+	resolved := 0
+	resolve := func(name *string, flag *domain.TruncatedName, known []string) {
+		if flag == nil || flag.Confidence == domain.NameResolved {
+			return
+		}
+		full, resolution := ResolvePrefix(flag.Truncated, known)
+		*name, *flag = full, resolution
+		if resolution.Confidence == domain.NameResolved {
+			resolved++
+		}
+	}
+	for i := range f.MostExecuted {
+		e := &f.MostExecuted[i]
+		resolve(&e.FilterName, e.FilterNameTruncated, knownFilters)
+	}
+	for i := range f.ExecutedPerTxn {
+		e := &f.ExecutedPerTxn[i]
+		resolve(&e.FilterName, e.FilterNameTruncated, knownFilters)
+	}
+	for i := range f.PerTransaction {
+		e := &f.PerTransaction[i]
+		resolve(&e.Form, e.FormTruncated, knownForms)
+	}
+	for i := range f.FilterLevels {
+		e := &f.FilterLevels[i]
+		resolve(&e.Form, e.FormTruncated, knownForms)
+	}
+	return resolved
+}
+
+// reportForms returns the forms the report names outside its filter
+// sections.
+func reportForms(result *domain.ParseResult) []string {
+	data := result.Dashboard
+	var forms []string
+	for _, entries := range [][]domain.TopNEntry{data.TopAPICalls, data.TopSQL, data.TopEscalations} {
+		for _, e := range entries {
+			if e.Form != "" {
+				forms = append(forms, e.Form)
+			}
+		}
+	}
+	for form := range data.Distribution["forms"] {
+		forms = append(forms, form)
+	}
+	return forms
+}
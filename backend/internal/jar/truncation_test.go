@@ -0,0 +1,98 @@
+package jar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestResolvePrefix(t *testing.T) {
+	t.Run("one match is the full name", func(t *testing.T) {
+		name, res := ResolvePrefix("HPD:INC:SendNotific", []string{"HPD:Help Desk", "HPD:INC:SendNotification", "HPD:INC:SendNotification"})
+		assert.Equal(t, "HPD:INC:SendNotification", name)
+		assert.Equal(t, domain.NameResolved, res.Confidence)
+		assert.Equal(t, "HPD:INC:SendNotific", res.Truncated)
+		assert.Empty(t, res.Candidates)
+	})
+
+	t.Run("several matches are ambiguous", func(t *testing.T) {
+		name, res := ResolvePrefix("HPD:INC:Set", []string{"HPD:INC:SetStatus", "HPD:INC:SetAssignee", "HPD:Help Desk"})
+		assert.Equal(t, "HPD:INC:Set", name, "an ambiguous name keeps its prefix")
+		assert.Equal(t, domain.NameAmbiguous, res.Confidence)
+		assert.Equal(t, []string{"HPD:INC:SetAssignee", "HPD:INC:SetStatus"}, res.Candidates)
+	})
+
+	t.Run("candidates are capped", func(t *testing.T) {
+		known := make([]string, 0, maxNameCandidates+5)
+		for i := 0; i < maxNameCandidates+5; i++ {
+			known = append(known, "F:"+string(rune('a'+i)))
+		}
+		_, res := ResolvePrefix("F:", known)
+		assert.Equal(t, domain.NameAmbiguous, res.Confidence)
+		assert.Len(t, res.Candidates, maxNameCandidates)
+	})
+
+	t.Run("no match is unresolved", func(t *testing.T) {
+		name, res := ResolvePrefix("CHG:Approve", []string{"HPD:Help Desk"})
+		assert.Equal(t, "CHG:Approve", name)
+		assert.Equal(t, domain.NameUnresolved, res.Confidence)
+	})
+
+	t.Run("truncated known names are ignored", func(t *testing.T) {
+		name, res := ResolvePrefix("HPD:INC:Send", []string{"HPD:INC:SendNot" + TruncationMarker, "HPD:INC:SendNotification"})
+		assert.Equal(t, "HPD:INC:SendNotification", name)
+		assert.Equal(t, domain.NameResolved, res.Confidence)
+	})
+
+	t.Run("names ending in punctuation", func(t *testing.T) {
+		name, res := ResolvePrefix("Set:Status?", []string{"Set:Status?-Done", "Set:Status"})
+		assert.Equal(t, "Set:Status?-Done", name)
+		assert.Equal(t, domain.NameResolved, res.Confidence)
+	})
+}
+
+func TestResolveTruncatedNames(t *testing.T) {
+	f := &domain.JARFilterComplexityResponse{
+		LongestRunning: []domain.TopNEntry{{Identifier: "HPD:INC:SendNotification", Form: "HPD:Help Desk"}},
+		MostExecuted: []domain.JARFilterMostExecuted{
+			{FilterName: "HPD:INC:SendNotific" + TruncationMarker},
+			{FilterName: "HPD:INC:Notify!"},
+			{FilterName: "Set:Status?" + TruncationMarker},
+			{FilterName: "CHG:Approve" + TruncationMarker},
+		},
+		ExecutedPerTxn: []domain.JARFilterExecutedPerTxn{
+			{FilterName: "HPD:INC:Set" + TruncationMarker},
+		},
+		PerTransaction: []domain.JARFilterPerTransaction{{Form: "HPD:Help" + TruncationMarker}},
+		FilterLevels:   []domain.JARFilterLevel{{Form: "CHG:Infra" + TruncationMarker}},
+	}
+
+	resolved := ResolveTruncatedNames(f, []string{"Set:Status?-Done", "HPD:INC:SetStatus", "HPD:INC:SetAssignee"}, nil)
+	assert.Equal(t, 3, resolved)
+
+	me := f.MostExecuted
+	assert.Equal(t, "HPD:INC:SendNotification", me[0].FilterName, "resolved from another section")
+	assert.Equal(t, &domain.TruncatedName{Truncated: "HPD:INC:SendNotific", Confidence: domain.NameResolved}, me[0].FilterNameTruncated)
+	assert.Equal(t, "HPD:INC:Notify!", me[1].FilterName, "a name ending in punctuation is not truncated")
+	assert.Nil(t, me[1].FilterNameTruncated)
+	assert.Equal(t, "Set:Status?-Done", me[2].FilterName, "resolved from the names given")
+	assert.Equal(t, "CHG:Approve", me[3].FilterName)
+	assert.Equal(t, domain.NameUnresolved, me[3].FilterNameTruncated.Confidence)
+
+	ept := f.ExecutedPerTxn[0]
+	assert.Equal(t, "HPD:INC:Set", ept.FilterName)
+	assert.Equal(t, domain.NameAmbiguous, ept.FilterNameTruncated.Confidence)
+	assert.Equal(t, []string{"HPD:INC:SetAssignee", "HPD:INC:SetStatus"}, ept.FilterNameTruncated.Candidates)
+
+	assert.Equal(t, "HPD:Help Desk", f.PerTransaction[0].Form)
+	assert.Equal(t, "CHG:Infra", f.FilterLevels[0].Form)
+	assert.Equal(t, domain.NameUnresolved, f.FilterLevels[0].FormTruncated.Confidence)
+
+	// Resolving again keeps resolved names and retries the others.
+	assert.Zero(t, ResolveTruncatedNames(f, nil, nil))
+	assert.Equal(t, 1, ResolveTruncatedNames(f, nil, []string{"CHG:Infrastructure Change"}))
+	assert.Equal(t, "CHG:Infrastructure Change", f.FilterLevels[0].Form)
+	assert.Equal(t, "HPD:INC:SendNotification", f.MostExecuted[0].FilterName)
+}
@@ -0,0 +1,181 @@
+// Package jobevents records the event log of analysis jobs: the stages the
+// pipeline went through, progress milestones, retries and NATS publishes.
+// Events are queued in memory and written in batches from Run, so logging
+// never blocks or fails the job it describes. The log is replayed as a
+// timeline to debug analyses that are stuck or slow.
+package jobevents
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/batch"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// DefaultBufferSize is the number of events queued before new ones are
+	// dropped.
+	DefaultBufferSize = batch.DefaultBufferSize
+
+	// DefaultFlushInterval is how often queued events are written.
+	DefaultFlushInterval = 2 * time.Second
+
+	// DefaultMaxPerJob caps the events recorded for one job, so that a job
+	// looping on retries cannot flood the table.
+	DefaultMaxPerJob = 500
+
+	// jobIdleTTL is how long the count of a job is kept after its last
+	// event when the job never reported a terminal event.
+	jobIdleTTL = time.Hour
+)
+
+// Store persists job events.
+type Store interface {
+	AppendJobEvents(ctx context.Context, events []domain.JobEvent) (int, error)
+}
+
+// jobCount is the number of events accepted and dropped for one job.
+type jobCount struct {
+	accepted int
+	dropped  int
+	lastSeen time.Time
+}
+
+// Appender buffers job events and writes them to the store from Run.
+type Appender struct {
+	store     Store
+	source    string
+	writer    *batch.Writer[domain.JobEvent]
+	maxPerJob int
+
+	mu     sync.Mutex
+	counts map[uuid.UUID]*jobCount
+
+	dropped atomic.Int64
+}
+
+// NewAppender creates an Appender tagging its events with source, queueing
+// up to bufferSize events and recording at most maxPerJob events per job.
+// Non-positive values select the defaults.
+func NewAppender(store Store, source string, bufferSize, maxPerJob int, flushInterval time.Duration) *Appender {
+	if maxPerJob <= 0 {
+		maxPerJob = DefaultMaxPerJob
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	a := &Appender{
+		store:     store,
+		source:    source,
+		maxPerJob: maxPerJob,
+		counts:    make(map[uuid.UUID]*jobCount),
+	}
+	a.writer = batch.NewWriter(a.write, bufferSize, flushInterval)
+	a.writer.OnTick(func() { a.pruneCounts(time.Now().Add(-jobIdleTTL)) })
+	return a
+}
+
+// Append queues an event without blocking. Events beyond the per-job cap or
+// a full buffer are dropped and counted. A terminal event (completed or
+// failed) is always queued, preceded by an events_dropped event when some
+// of the job's events were dropped, and flushed right away. A nil Appender
+// ignores every event.
+func (a *Appender) Append(e domain.JobEvent) {
+	if a == nil {
+		return
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+	if e.Source == "" {
+		e.Source = a.source
+	}
+
+	terminal := e.Kind.Terminal()
+	a.mu.Lock()
+	c := a.counts[e.JobID]
+	if c == nil {
+		c = &jobCount{}
+		a.counts[e.JobID] = c
+	}
+	c.lastSeen = e.OccurredAt
+	if !terminal && c.accepted >= a.maxPerJob {
+		c.dropped++
+		a.mu.Unlock()
+		a.dropped.Add(1)
+		return
+	}
+	c.accepted++
+	dropped := c.dropped
+	if terminal {
+		delete(a.counts, e.JobID)
+	}
+	a.mu.Unlock()
+
+	if terminal && dropped > 0 {
+		a.enqueue(domain.JobEvent{
+			TenantID:   e.TenantID,
+			JobID:      e.JobID,
+			Source:     e.Source,
+			Kind:       domain.JobEventDropped,
+			Message:    "events over the per-job cap were not recorded",
+			Metadata:   map[string]any{"dropped": dropped, "cap": a.maxPerJob},
+			OccurredAt: e.OccurredAt,
+		})
+	}
+	a.enqueue(e)
+	if terminal {
+		a.writer.Flush()
+	}
+}
+
+func (a *Appender) enqueue(e domain.JobEvent) {
+	if !a.writer.Offer(e) {
+		a.dropped.Add(1)
+		slog.Warn("job event buffer full, event dropped",
+			"tenant_id", e.TenantID.String(),
+			"job_id", e.JobID.String(),
+			"kind", e.Kind,
+		)
+	}
+}
+
+// DroppedCount returns the number of events dropped so far, over the
+// per-job cap or because the buffer was full.
+func (a *Appender) DroppedCount() int64 {
+	if a == nil {
+		return 0
+	}
+	return a.dropped.Load()
+}
+
+// Run writes queued events until ctx is cancelled, then writes the events
+// still queued and returns.
+func (a *Appender) Run(ctx context.Context) {
+	a.writer.Run(ctx)
+}
+
+// write stores a batch of events. Failed writes are logged and dropped; the
+// event log is a debugging aid, not a record of truth.
+func (a *Appender) write(ctx context.Context, events []domain.JobEvent) {
+	if _, err := a.store.AppendJobEvents(ctx, events); err != nil {
+		slog.Warn("failed to append job events", "count", len(events), "error", err)
+	}
+}
+
+// pruneCounts forgets the counts of jobs without events since before.
+func (a *Appender) pruneCounts(before time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, c := range a.counts {
+		if c.lastSeen.Before(before) {
+			delete(a.counts, id)
+		}
+	}
+}
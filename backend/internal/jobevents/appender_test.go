@@ -0,0 +1,130 @@
+package jobevents
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// memoryStore records the batches written to it.
+type memoryStore struct {
+	mu      sync.Mutex
+	batches [][]domain.JobEvent
+}
+
+func (s *memoryStore) AppendJobEvents(_ context.Context, events []domain.JobEvent) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]domain.JobEvent(nil), events...))
+	return len(events), nil
+}
+
+func (s *memoryStore) events() []domain.JobEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var all []domain.JobEvent
+	for _, b := range s.batches {
+		all = append(all, b...)
+	}
+	return all
+}
+
+func kinds(events []domain.JobEvent) []domain.JobEventKind {
+	out := make([]domain.JobEventKind, len(events))
+	for i, e := range events {
+		out[i] = e.Kind
+	}
+	return out
+}
+
+func TestAppender_FlushesOnCompletion(t *testing.T) {
+	store := &memoryStore{}
+	a := NewAppender(store, domain.JobEventSourceWorker, 16, 0, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+
+	job := uuid.New()
+	a.Append(domain.JobEvent{JobID: job, Kind: domain.JobEventStageStarted, Stage: "jar"})
+	a.Append(domain.JobEvent{JobID: job, Kind: domain.JobEventStageFinished, Stage: "jar"})
+	a.Append(domain.JobEvent{JobID: job, Kind: domain.JobEventCompleted})
+
+	// The flush interval is an hour: only the terminal event can flush.
+	require.Eventually(t, func() bool { return len(store.events()) == 3 }, time.Second, 5*time.Millisecond)
+	events := store.events()
+	assert.Equal(t, []domain.JobEventKind{domain.JobEventStageStarted, domain.JobEventStageFinished, domain.JobEventCompleted}, kinds(events))
+	for _, e := range events {
+		assert.Equal(t, domain.JobEventSourceWorker, e.Source)
+		assert.False(t, e.OccurredAt.IsZero())
+	}
+}
+
+func TestAppender_WritesOnStop(t *testing.T) {
+	store := &memoryStore{}
+	a := NewAppender(store, domain.JobEventSourceAPI, 16, 0, time.Hour)
+	a.Append(domain.JobEvent{JobID: uuid.New(), Kind: domain.JobEventCreated})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	assert.Equal(t, []domain.JobEventKind{domain.JobEventCreated}, kinds(store.events()))
+}
+
+func TestAppender_CapsEventsPerJob(t *testing.T) {
+	store := &memoryStore{}
+	a := NewAppender(store, domain.JobEventSourceWorker, 16, 2, time.Hour)
+
+	job, other := uuid.New(), uuid.New()
+	for i := 0; i < 5; i++ {
+		a.Append(domain.JobEvent{JobID: job, Kind: domain.JobEventRetry})
+	}
+	a.Append(domain.JobEvent{JobID: other, Kind: domain.JobEventProgress})
+	a.Append(domain.JobEvent{JobID: job, Kind: domain.JobEventFailed})
+	assert.Equal(t, int64(3), a.DroppedCount())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.Run(ctx)
+
+	var forJob []domain.JobEvent
+	for _, e := range store.events() {
+		if e.JobID == job {
+			forJob = append(forJob, e)
+		}
+	}
+	assert.Equal(t, []domain.JobEventKind{domain.JobEventRetry, domain.JobEventRetry, domain.JobEventDropped, domain.JobEventFailed}, kinds(forJob),
+		"the terminal event is kept beyond the cap, after a note of the dropped ones")
+	assert.Equal(t, 3, forJob[2].Metadata["dropped"])
+	assert.Len(t, store.events(), 5, "other jobs have their own cap")
+}
+
+func TestAppender_NonBlockingWhenFull(t *testing.T) {
+	a := NewAppender(&memoryStore{}, domain.JobEventSourceWorker, 1, 0, time.Hour)
+
+	// Nothing drains the buffer: the second event must not block.
+	a.Append(domain.JobEvent{JobID: uuid.New(), Kind: domain.JobEventProgress})
+	a.Append(domain.JobEvent{JobID: uuid.New(), Kind: domain.JobEventProgress})
+	assert.Equal(t, 1, a.writer.Len())
+	assert.Equal(t, int64(1), a.DroppedCount())
+}
+
+func TestAppender_Nil(t *testing.T) {
+	var a *Appender
+	assert.NotPanics(t, func() {
+		a.Append(domain.JobEvent{JobID: uuid.New(), Kind: domain.JobEventCompleted})
+	})
+	assert.Zero(t, a.DroppedCount())
+}
@@ -0,0 +1,33 @@
+package jobevents
+
+import (
+	"sort"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// Timeline orders the events of a job by time, keeping the write order of
+// events at the same instant, and adds the time elapsed since the previous
+// event and since the first one. Negative gaps, from clock differences
+// between the API and the workers, are reported as zero.
+func Timeline(events []domain.JobEvent) []domain.JobTimelineEvent {
+	sorted := make([]domain.JobEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].OccurredAt.Equal(sorted[j].OccurredAt) {
+			return sorted[i].OccurredAt.Before(sorted[j].OccurredAt)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	out := make([]domain.JobTimelineEvent, len(sorted))
+	for i, e := range sorted {
+		out[i].JobEvent = e
+		if i == 0 {
+			continue
+		}
+		out[i].SincePreviousMS = max(e.OccurredAt.Sub(sorted[i-1].OccurredAt).Milliseconds(), 0)
+		out[i].SinceStartMS = max(e.OccurredAt.Sub(sorted[0].OccurredAt).Milliseconds(), 0)
+	}
+	return out
+}
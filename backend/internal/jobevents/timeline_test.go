@@ -0,0 +1,34 @@
+package jobevents
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestTimeline(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	got := Timeline([]domain.JobEvent{
+		{ID: 4, Kind: domain.JobEventStageFinished, Stage: "jar", OccurredAt: t0.Add(90 * time.Second)},
+		{ID: 3, Kind: domain.JobEventStageStarted, Stage: "jar", OccurredAt: t0.Add(1500 * time.Millisecond)},
+		{ID: 1, Kind: domain.JobEventCreated, OccurredAt: t0},
+		{ID: 2, Kind: domain.JobEventPublish, OccurredAt: t0},
+	})
+
+	require.Len(t, got, 4)
+	var order []int64
+	for _, e := range got {
+		order = append(order, e.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4}, order, "ordered by time, then by write order")
+	assert.Equal(t, int64(0), got[1].SincePreviousMS)
+	assert.Equal(t, int64(1500), got[2].SincePreviousMS)
+	assert.Equal(t, int64(88500), got[3].SincePreviousMS)
+	assert.Equal(t, int64(90000), got[3].SinceStartMS)
+
+	assert.Empty(t, Timeline(nil))
+}
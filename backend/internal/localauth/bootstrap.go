@@ -0,0 +1,52 @@
+package localauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// TenantOrgID is the clerk_org_id of the tenant the bootstrap admin is
+// created in; local tenants have no Clerk organization.
+const TenantOrgID = "local"
+
+// Bootstrap creates the first admin, with email and password, when there
+// are no local users yet, and reports whether it did. Its tenant is the
+// local tenant, created first if needed. Without an email nothing is done.
+func Bootstrap(ctx context.Context, pg storage.PostgresStore, email, password string) (bool, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return false, nil
+	}
+	n, err := pg.CountLocalUsers(ctx)
+	if err != nil {
+		return false, fmt.Errorf("localauth: bootstrap: %w", err)
+	}
+	if n > 0 {
+		return false, nil
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return false, fmt.Errorf("localauth: bootstrap admin: %w", err)
+	}
+
+	tenant, err := pg.GetTenantByClerkOrg(ctx, TenantOrgID)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			return false, fmt.Errorf("localauth: bootstrap: %w", err)
+		}
+		tenant = &domain.Tenant{ClerkOrgID: TenantOrgID, Name: "Local", Plan: "enterprise", StorageLimitGB: 1000}
+		if err := pg.CreateTenant(ctx, tenant); err != nil {
+			return false, fmt.Errorf("localauth: bootstrap: %w", err)
+		}
+	}
+
+	admin := &domain.LocalUser{TenantID: tenant.ID, Email: email, PasswordHash: hash, Role: domain.LocalUserAdmin}
+	if err := pg.CreateLocalUser(ctx, admin); err != nil {
+		return false, fmt.Errorf("localauth: bootstrap: %w", err)
+	}
+	return true, nil
+}
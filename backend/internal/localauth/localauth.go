@@ -0,0 +1,68 @@
+// Package localauth is the auth provider of deployments that cannot reach
+// Clerk, e.g. air-gapped ones: users stored in Postgres sign in with a
+// password and get a stateless token signed with a key from the
+// configuration. It is only enabled with AUTH_PROVIDER=local.
+package localauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// MinPasswordLength is the shortest password a local user may have.
+const MinPasswordLength = 12
+
+// ErrInvalidCredentials is returned for an unknown email, a wrong password
+// or a disabled user alike, so that a failed sign-in does not tell which.
+var ErrInvalidCredentials = errors.New("localauth: invalid email or password")
+
+// dummyHash is compared against when the email is unknown, so that a
+// sign-in takes as long whether or not the user exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("remedyiq-unknown-user"), bcrypt.DefaultCost)
+
+// HashPassword returns the bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("localauth: hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash. An empty hash is
+// checked against a dummy one.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Authenticate returns the enabled local user with email and password.
+// Unknown and disabled users are checked against the dummy hash too, so that
+// every failed sign-in looks and takes the same.
+func Authenticate(ctx context.Context, pg storage.PostgresStore, email, password string) (*domain.LocalUser, error) {
+	user, err := pg.GetLocalUserByEmail(ctx, strings.TrimSpace(email))
+	if err != nil && !storage.IsNotFound(err) {
+		return nil, fmt.Errorf("localauth: authenticate: %w", err)
+	}
+	hash := ""
+	if user != nil && !user.Disabled {
+		hash = user.PasswordHash
+	}
+	if !CheckPassword(hash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
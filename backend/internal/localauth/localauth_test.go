@@ -0,0 +1,120 @@
+package localauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+const testPassword = "correct-horse-battery"
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword(testPassword)
+	require.NoError(t, err)
+	assert.NotContains(t, hash, testPassword)
+	assert.True(t, CheckPassword(hash, testPassword))
+	assert.False(t, CheckPassword(hash, "wrong-password-123"))
+	assert.False(t, CheckPassword("", testPassword))
+
+	_, err = HashPassword("short")
+	assert.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	hash, err := HashPassword(testPassword)
+	require.NoError(t, err)
+	user := &domain.LocalUser{ID: uuid.New(), TenantID: uuid.New(), Email: "ops@example.com", PasswordHash: hash, Role: domain.LocalUserAdmin}
+	disabled := &domain.LocalUser{ID: uuid.New(), TenantID: uuid.New(), Email: "gone@example.com", PasswordHash: hash, Disabled: true}
+
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetLocalUserByEmail", mock.Anything, "ops@example.com").Return(user, nil)
+	pg.On("GetLocalUserByEmail", mock.Anything, "gone@example.com").Return(disabled, nil)
+	pg.On("GetLocalUserByEmail", mock.Anything, "nobody@example.com").Return(nil, errors.New("postgres: local user not found: nobody@example.com"))
+	pg.On("GetLocalUserByEmail", mock.Anything, "down@example.com").Return(nil, errors.New("connection refused"))
+	ctx := context.Background()
+
+	got, err := Authenticate(ctx, pg, " ops@example.com ", testPassword)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	_, err = Authenticate(ctx, pg, "ops@example.com", "wrong-password-123")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = Authenticate(ctx, pg, "gone@example.com", testPassword)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = Authenticate(ctx, pg, "nobody@example.com", testPassword)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = Authenticate(ctx, pg, "down@example.com", testPassword)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestBootstrap_CreatesAdminAndTenant(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("CountLocalUsers", mock.Anything).Return(0, nil)
+	pg.On("GetTenantByClerkOrg", mock.Anything, TenantOrgID).Return(nil, errors.New("postgres: tenant not found"))
+	tenantID := uuid.New()
+	pg.On("CreateTenant", mock.Anything, mock.MatchedBy(func(tn *domain.Tenant) bool {
+		return tn.ClerkOrgID == TenantOrgID
+	})).Run(func(args mock.Arguments) { args.Get(1).(*domain.Tenant).ID = tenantID }).Return(nil)
+	pg.On("CreateLocalUser", mock.Anything, mock.MatchedBy(func(u *domain.LocalUser) bool {
+		return u.TenantID == tenantID && u.Email == "admin@example.com" && u.Role == domain.LocalUserAdmin &&
+			CheckPassword(u.PasswordHash, testPassword)
+	})).Return(nil)
+
+	created, err := Bootstrap(context.Background(), pg, "admin@example.com", testPassword)
+	require.NoError(t, err)
+	assert.True(t, created)
+	pg.AssertExpectations(t)
+}
+
+func TestBootstrap_ReusesLocalTenant(t *testing.T) {
+	tenant := &domain.Tenant{ID: uuid.New(), ClerkOrgID: TenantOrgID}
+	pg := new(testutil.MockPostgresStore)
+	pg.On("CountLocalUsers", mock.Anything).Return(0, nil)
+	pg.On("GetTenantByClerkOrg", mock.Anything, TenantOrgID).Return(tenant, nil)
+	pg.On("CreateLocalUser", mock.Anything, mock.MatchedBy(func(u *domain.LocalUser) bool {
+		return u.TenantID == tenant.ID
+	})).Return(nil)
+
+	created, err := Bootstrap(context.Background(), pg, "admin@example.com", testPassword)
+	require.NoError(t, err)
+	assert.True(t, created)
+	pg.AssertNotCalled(t, "CreateTenant", mock.Anything, mock.Anything)
+}
+
+func TestBootstrap_SkipsWhenUsersExist(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("CountLocalUsers", mock.Anything).Return(3, nil)
+
+	created, err := Bootstrap(context.Background(), pg, "admin@example.com", testPassword)
+	require.NoError(t, err)
+	assert.False(t, created)
+	pg.AssertNotCalled(t, "CreateLocalUser", mock.Anything, mock.Anything)
+}
+
+func TestBootstrap_NoEmail(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+
+	created, err := Bootstrap(context.Background(), pg, "", "")
+	require.NoError(t, err)
+	assert.False(t, created)
+	pg.AssertExpectations(t)
+}
+
+func TestBootstrap_WeakPassword(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("CountLocalUsers", mock.Anything).Return(0, nil)
+
+	_, err := Bootstrap(context.Background(), pg, "admin@example.com", "short")
+	require.Error(t, err)
+	pg.AssertNotCalled(t, "CreateLocalUser", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,148 @@
+package localauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/api/middleware"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var (
+	keyA = strings.Repeat("a", 32)
+	keyB = strings.Repeat("b", 32)
+)
+
+func testUser(role domain.LocalUserRole) *domain.LocalUser {
+	return &domain.LocalUser{ID: uuid.New(), TenantID: uuid.New(), Email: "ops@example.com", Role: role}
+}
+
+func TestIssuer_RoundTrip(t *testing.T) {
+	iss, err := NewIssuer([]string{keyA}, time.Hour)
+	require.NoError(t, err)
+	user := testUser(domain.LocalUserAdmin)
+
+	token, exp, err := iss.Issue(user)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), exp, 5*time.Second)
+
+	id, err := iss.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), id.UserID)
+	assert.Equal(t, user.TenantID.String(), id.TenantID)
+	assert.Equal(t, user.TenantID.String(), id.OrgID)
+	assert.Equal(t, "org:admin", id.OrgRole)
+}
+
+func TestIssuer_MemberRole(t *testing.T) {
+	iss, err := NewIssuer([]string{keyA}, time.Hour)
+	require.NoError(t, err)
+
+	token, _, err := iss.Issue(testUser(domain.LocalUserMember))
+	require.NoError(t, err)
+	id, err := iss.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "org:member", id.OrgRole)
+}
+
+func TestIssuer_Rotation(t *testing.T) {
+	old, err := NewIssuer([]string{keyA}, time.Hour)
+	require.NoError(t, err)
+	oldToken, _, err := old.Issue(testUser(domain.LocalUserMember))
+	require.NoError(t, err)
+
+	// The new key is prepended: new tokens use it, old ones still verify.
+	rotated, err := NewIssuer([]string{keyB, keyA}, time.Hour)
+	require.NoError(t, err)
+	_, err = rotated.Verify(oldToken)
+	require.NoError(t, err)
+	newToken, _, err := rotated.Issue(testUser(domain.LocalUserMember))
+	require.NoError(t, err)
+	_, err = old.Verify(newToken)
+	require.Error(t, err, "a key dropped from the configuration no longer verifies")
+
+	// Once the old key is dropped, its tokens are refused.
+	dropped, err := NewIssuer([]string{keyB}, time.Hour)
+	require.NoError(t, err)
+	_, err = dropped.Verify(oldToken)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown key")
+	_, err = dropped.Verify(newToken)
+	require.NoError(t, err)
+}
+
+func TestIssuer_Expired(t *testing.T) {
+	iss, err := NewIssuer([]string{keyA}, time.Minute)
+	require.NoError(t, err)
+	issuedAt := time.Now().Add(-time.Hour)
+	iss.now = func() time.Time { return issuedAt }
+	token, _, err := iss.Issue(testUser(domain.LocalUserMember))
+	require.NoError(t, err)
+
+	iss.now = time.Now
+	_, err = iss.Verify(token)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expired")
+}
+
+func TestIssuer_TamperedPayload(t *testing.T) {
+	iss, err := NewIssuer([]string{keyA}, time.Hour)
+	require.NoError(t, err)
+	token, _, err := iss.Issue(testUser(domain.LocalUserMember))
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"remedyiq-local","sub":"x","role":"admin","exp":9999999999}`))
+	_, err = iss.Verify(strings.Join(parts, "."))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "signature")
+}
+
+func TestIssuer_RejectsForeignTokens(t *testing.T) {
+	iss, err := NewIssuer([]string{keyA}, time.Hour)
+	require.NoError(t, err)
+	kid := iss.keys[0].id
+
+	signed := func(header, payload string) string {
+		in := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+		mac := hmac.New(sha256.New, []byte(keyA))
+		mac.Write([]byte(in))
+		return in + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"malformed", "not-a-token", "malformed"},
+		{"no kid", signed(`{"alg":"HS256","typ":"JWT"}`, `{"sub":"user_1","exp":9999999999}`), "unknown key"},
+		{"none alg", signed(`{"alg":"none","kid":"`+kid+`"}`, `{"sub":"user_1","exp":9999999999}`), "unsupported algorithm"},
+		{"clerk issuer", signed(`{"alg":"HS256","kid":"`+kid+`"}`, `{"sub":"user_1","exp":9999999999}`), "not issued by the local provider"},
+		{"no subject", signed(`{"alg":"HS256","kid":"`+kid+`"}`, `{"iss":"remedyiq-local","exp":9999999999}`), middleware.ErrMissingSubject.Error()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := iss.Verify(tc.token)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestNewIssuer_Validation(t *testing.T) {
+	_, err := NewIssuer(nil, time.Hour)
+	assert.Error(t, err)
+	_, err = NewIssuer([]string{"short"}, time.Hour)
+	assert.Error(t, err)
+	_, err = NewIssuer([]string{keyA}, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package logparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// formatHeadLines is the number of lines sampled from the start of a file.
+	formatHeadLines = 5000
+
+	// formatWindowLines is the number of lines sampled from the middle and
+	// the tail of large files, so concatenated logs are not judged by their
+	// first segment alone.
+	formatWindowLines = 1000
+
+	// formatMixedShare is the minority share (in percent) above which a
+	// file is reported as mixed.
+	formatMixedShare = 5
+)
+
+// bracketPrefixRegex matches the "<TYPE> " prefix shared by every AR Server
+// log layout since 7.x.
+var bracketPrefixRegex = regexp.MustCompile(`^<\w{3,4}\s*>\s*<`)
+
+// serverVersionRegex extracts the server version from a log banner, e.g.
+// "Action Request System(R) Server x64 Version 20.02.00 ...".
+var serverVersionRegex = regexp.MustCompile(`(?i)(?:Action Request System|AR System).*?Version[:\s]+(\d+)\.(\d+)`)
+
+// FormatDetection summarises the markers found while sampling a log file.
+type FormatDetection struct {
+	Format        domain.LogFormat `json:"format"`
+	ServerVersion string           `json:"server_version,omitempty"`
+	ModernLines   int              `json:"modern_lines"` // Bracket lines with a TrID field
+	LegacyLines   int              `json:"legacy_lines"` // Bracket lines without a TrID field
+	SampledLines  int              `json:"sampled_lines"`
+	Mixed         bool             `json:"mixed"`
+}
+
+// DetectFileFormat samples the head, middle and tail of the file at path and
+// classifies its log layout.
+func DetectFileFormat(path string) (*FormatDetection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat file: %w", err)
+	}
+
+	d := &FormatDetection{}
+	sampledEnd, err := sampleLines(f, formatHeadLines, false, d)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only files larger than the head sample have a middle and tail worth
+	// reading; the window offsets never overlap what was already sampled.
+	size := info.Size()
+	for _, offset := range []int64{size / 2, size - size/10} {
+		if offset <= sampledEnd {
+			continue
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seek to %d: %w", offset, err)
+		}
+		n, err := sampleLines(f, formatWindowLines, true, d)
+		if err != nil {
+			return nil, err
+		}
+		sampledEnd = offset + n
+	}
+
+	d.classify()
+	return d, nil
+}
+
+// DetectFormat classifies the log layout of the first maxLines lines of r.
+func DetectFormat(r io.Reader, maxLines int) (*FormatDetection, error) {
+	d := &FormatDetection{}
+	if _, err := sampleLines(r, maxLines, false, d); err != nil {
+		return nil, err
+	}
+	d.classify()
+	return d, nil
+}
+
+// sampleLines reads up to maxLines lines from r into d. When skipPartial is
+// set the first line is discarded because the reader starts mid-line. It
+// returns the number of bytes consumed.
+func sampleLines(r io.Reader, maxLines int, skipPartial bool, d *FormatDetection) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var consumed int64
+	for n := 0; n < maxLines && scanner.Scan(); n++ {
+		line := scanner.Text()
+		consumed += int64(len(line)) + 1
+		if skipPartial && n == 0 {
+			continue
+		}
+		d.SampledLines++
+		d.observe(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return consumed, fmt.Errorf("sample lines: %w", err)
+	}
+	return consumed, nil
+}
+
+func (d *FormatDetection) observe(line string) {
+	if d.ServerVersion == "" {
+		if m := serverVersionRegex.FindStringSubmatch(line); m != nil {
+			d.ServerVersion = m[1] + "." + m[2]
+		}
+	}
+	if !bracketPrefixRegex.MatchString(line) {
+		return
+	}
+	if !strings.Contains(line, "<TID:") || !strings.Contains(line, "<RPC ID:") {
+		return
+	}
+	if strings.Contains(line, "<TrID:") {
+		d.ModernLines++
+	} else {
+		d.LegacyLines++
+	}
+}
+
+// classify derives Format and Mixed from the sampled counts. The majority
+// layout wins; the banner version, when present, separates 20.x servers
+// (which already log TrIDs) from the 25.x layout the bundled JAR targets.
+func (d *FormatDetection) classify() {
+	total := d.ModernLines + d.LegacyLines
+	if total > 0 {
+		minority := min(d.ModernLines, d.LegacyLines)
+		d.Mixed = minority*100/total >= formatMixedShare
+	}
+
+	major := 0
+	if d.ServerVersion != "" {
+		major, _ = strconv.Atoi(strings.SplitN(d.ServerVersion, ".", 2)[0])
+	}
+
+	switch {
+	case total == 0 && major == 0:
+		d.Format = domain.LogFormatUnknown
+	case d.LegacyLines > d.ModernLines, total == 0 && major < 19:
+		d.Format = domain.LogFormatAR9
+	case major > 0 && major < 25:
+		d.Format = domain.LogFormatAR20
+	default:
+		d.Format = domain.LogFormatAR25
+	}
+}
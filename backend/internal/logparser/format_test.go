@@ -0,0 +1,104 @@
+package logparser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("..", "..", "testdata", name))
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestDetectFormat_Fixtures(t *testing.T) {
+	cases := []struct {
+		fixture string
+		want    domain.LogFormat
+		version string
+	}{
+		{"ar25_sample.log", domain.LogFormatAR25, ""},
+		{"ar9_legacy_sample.log", domain.LogFormatAR9, "9.1"},
+		{"arapi_sample.log", domain.LogFormatUnknown, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			d, err := DetectFormat(strings.NewReader(readFixture(t, tc.fixture)), formatHeadLines)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, d.Format)
+			assert.Equal(t, tc.version, d.ServerVersion)
+			assert.False(t, d.Mixed)
+		})
+	}
+}
+
+func TestDetectFormat_BannerSeparates20x(t *testing.T) {
+	log := "/* Mon Jan 05 2026 09:00:00.0000 */ Action Request System(R) Server x64 Version 20.02.00 202002121010\n" +
+		sampleAPI + "\n" + sampleSQL + "\n"
+
+	d, err := DetectFormat(strings.NewReader(log), formatHeadLines)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LogFormatAR20, d.Format)
+	assert.Equal(t, "20.02", d.ServerVersion)
+	assert.Equal(t, 2, d.ModernLines)
+}
+
+func TestDetectFormat_HonoursLineLimit(t *testing.T) {
+	log := readFixture(t, "ar25_sample.log") + readFixture(t, "ar9_legacy_sample.log")
+
+	d, err := DetectFormat(strings.NewReader(log), 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, d.SampledLines)
+	assert.Equal(t, 0, d.LegacyLines)
+	assert.Equal(t, domain.LogFormatAR25, d.Format)
+}
+
+func TestDetectFileFormat_ConcatenatedFile(t *testing.T) {
+	modern := readFixture(t, "ar25_sample.log")
+	legacy := readFixture(t, "ar9_legacy_sample.log")
+
+	// A modern head long enough to fill the head sample, followed by a
+	// larger legacy tail: only the middle and tail windows see legacy lines.
+	var b strings.Builder
+	for b.Len() < 6000*len(sampleAPI) {
+		b.WriteString(modern)
+	}
+	for i := 0; i < 8000; i++ {
+		b.WriteString(legacy)
+	}
+
+	path := filepath.Join(t.TempDir(), "concat.log")
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o644))
+
+	d, err := DetectFileFormat(path)
+	require.NoError(t, err)
+	assert.True(t, d.Mixed)
+	assert.Greater(t, d.ModernLines, 0)
+	assert.Greater(t, d.LegacyLines, 0)
+	assert.Equal(t, formatHeadLines+2*(formatWindowLines-1), d.SampledLines)
+}
+
+func TestDetectFileFormat_SmallFileReadsHeadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small.log")
+	require.NoError(t, os.WriteFile(path, []byte(readFixture(t, "ar9_legacy_sample.log")), 0o644))
+
+	d, err := DetectFileFormat(path)
+	require.NoError(t, err)
+	assert.Equal(t, domain.LogFormatAR9, d.Format)
+	assert.Equal(t, 6, d.SampledLines)
+	assert.Equal(t, 5, d.LegacyLines)
+}
+
+func TestDetectFileFormat_MissingFile(t *testing.T) {
+	_, err := DetectFileFormat(filepath.Join(t.TempDir(), "missing.log"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,339 @@
+package logparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// integrityEdgeBytes is how much of the head and the tail of a file is
+	// read by the integrity checks.
+	integrityEdgeBytes = 1 << 20
+
+	// integrityWindowBytes and integrityWindows size the evenly spaced
+	// windows read between the head and the tail of large files.
+	integrityWindowBytes = 64 << 10
+	integrityWindows     = 14
+
+	// binaryFatalShare is the share of NUL bytes in the sample above which
+	// a file is treated as binary rather than text with a damaged region.
+	binaryFatalShare = 0.01
+
+	// invalidUTF8FatalShare is the share of bytes that are not valid UTF-8
+	// above which a file is rejected. Below it they are most likely Latin-1
+	// characters in user data, which the JAR copes with.
+	invalidUTF8FatalShare = 0.2
+
+	// maxAvgLineBytes is the average line length above which a file is not
+	// plausibly a log.
+	maxAvgLineBytes = 64 << 10
+
+	// arProbeLines is the number of leading non-empty lines of which at
+	// least one must look like an AR Server log line.
+	arProbeLines = 20
+)
+
+// bannerLineRegex matches a line that starts with an AR timestamp comment,
+// as the server banner does.
+var bannerLineRegex = regexp.MustCompile(`^/\*\s*\w{3} \w{3} +\d{1,2} \d{4} `)
+
+// sampleWindow is a stretch of the file read for the integrity checks.
+type sampleWindow struct {
+	offset int64
+	data   []byte
+}
+
+// ValidateLogFile checks that the size bytes of r look like an intact AR
+// Server log: text rather than binary, with AR log lines at both ends, a
+// plausible line length and a final newline. Large files are sampled at
+// the head, the tail and evenly spaced windows between.
+func ValidateLogFile(r io.ReaderAt, size int64) (*domain.FileIntegrity, error) {
+	report := &domain.FileIntegrity{SizeBytes: size, Issues: []domain.IntegrityIssue{}}
+	if size == 0 {
+		report.Issues = append(report.Issues, domain.IntegrityIssue{
+			Code: domain.IntegrityEmptyFile, Severity: domain.IntegrityFatal, Message: "the file is empty",
+		})
+		return report, nil
+	}
+
+	windows, err := readSampleWindows(r, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range windows {
+		report.SampledBytes += int64(len(w.data))
+	}
+
+	report.Issues = append(report.Issues, checkEncoding(windows, report.SampledBytes)...)
+	report.EstimatedLines = estimateLines(windows, size, report)
+	report.Issues = append(report.Issues, checkEdgeLines(windows, size)...)
+	return report, nil
+}
+
+// readSampleWindows reads the whole of small files and the head, tail and
+// evenly spaced middle windows of large ones.
+func readSampleWindows(r io.ReaderAt, size int64) ([]sampleWindow, error) {
+	read := func(offset, n int64) (sampleWindow, error) {
+		buf := make([]byte, n)
+		got, err := r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return sampleWindow{}, fmt.Errorf("read at %d: %w", offset, err)
+		}
+		return sampleWindow{offset: offset, data: buf[:got]}, nil
+	}
+
+	if size <= 2*integrityEdgeBytes+integrityWindows*integrityWindowBytes {
+		w, err := read(0, size)
+		if err != nil {
+			return nil, err
+		}
+		return []sampleWindow{w}, nil
+	}
+
+	windows := make([]sampleWindow, 0, integrityWindows+2)
+	head, err := read(0, integrityEdgeBytes)
+	if err != nil {
+		return nil, err
+	}
+	windows = append(windows, head)
+
+	middle := size - 2*integrityEdgeBytes
+	for i := int64(1); i <= integrityWindows; i++ {
+		offset := integrityEdgeBytes + middle*i/(integrityWindows+1) - integrityWindowBytes/2
+		w, err := read(offset, integrityWindowBytes)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+
+	tail, err := read(size-integrityEdgeBytes, integrityEdgeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return append(windows, tail), nil
+}
+
+// checkEncoding counts NUL bytes and bytes that are not valid UTF-8 in the
+// sampled windows. Either is fatal above its share of the sample and a
+// warning below it.
+func checkEncoding(windows []sampleWindow, sampled int64) []domain.IntegrityIssue {
+	var nul, invalid int64
+	firstNUL, firstInvalid := int64(-1), int64(-1)
+	for _, w := range windows {
+		data := w.data
+		i := 0
+		if w.offset > 0 {
+			// The window may start inside a multi-byte character.
+			for i < len(data) && i < utf8.UTFMax-1 && !utf8.RuneStart(data[i]) {
+				i++
+			}
+		}
+		for i < len(data) {
+			c := data[i]
+			if c == 0 {
+				if firstNUL < 0 {
+					firstNUL = w.offset + int64(i)
+				}
+				nul++
+				i++
+				continue
+			}
+			if c < utf8.RuneSelf {
+				i++
+				continue
+			}
+			if !utf8.FullRune(data[i:]) {
+				break // cut off by the end of the window
+			}
+			r, n := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && n == 1 {
+				if firstInvalid < 0 {
+					firstInvalid = w.offset + int64(i)
+				}
+				invalid++
+			}
+			i += n
+		}
+	}
+
+	var issues []domain.IntegrityIssue
+	if nul > 0 {
+		share := float64(nul) / float64(sampled)
+		issue := domain.IntegrityIssue{
+			Code:     domain.IntegrityBinaryContent,
+			Severity: domain.IntegrityWarning,
+			Offset:   &firstNUL,
+			Count:    nul,
+			Message: fmt.Sprintf("%d NUL bytes found, the first at byte %d; the damaged region will be skipped",
+				nul, firstNUL),
+		}
+		if share > binaryFatalShare {
+			issue.Severity = domain.IntegrityFatal
+			issue.Message = fmt.Sprintf("the file contains binary data (%.1f%% NUL bytes, the first at byte %d); it may be corrupted or not a log file",
+				share*100, firstNUL)
+		}
+		issues = append(issues, issue)
+	}
+	if invalid > 0 {
+		share := float64(invalid) / float64(sampled)
+		issue := domain.IntegrityIssue{
+			Code:     domain.IntegrityInvalidEncoding,
+			Severity: domain.IntegrityWarning,
+			Offset:   &firstInvalid,
+			Count:    invalid,
+			Message: fmt.Sprintf("%d bytes are not valid UTF-8, the first at byte %d; the file may mix character encodings",
+				invalid, firstInvalid),
+		}
+		if share > invalidUTF8FatalShare {
+			issue.Severity = domain.IntegrityFatal
+			issue.Message = fmt.Sprintf("%.0f%% of the file is not valid text, starting at byte %d; it may be corrupted or compressed",
+				share*100, firstInvalid)
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// estimateLines estimates the line count of the file from the line breaks
+// in the sample and adds an issue to report when the average line length
+// is implausible for a log.
+func estimateLines(windows []sampleWindow, size int64, report *domain.FileIntegrity) int64 {
+	var breaks int64
+	for _, w := range windows {
+		breaks += int64(bytes.Count(w.data, []byte{'\n'}))
+	}
+
+	if breaks == 0 {
+		if report.SampledBytes > maxAvgLineBytes {
+			report.Issues = append(report.Issues, domain.IntegrityIssue{
+				Code:     domain.IntegrityNoLineBreaks,
+				Severity: domain.IntegrityFatal,
+				Message:  fmt.Sprintf("no line breaks in %d sampled bytes; the file is not a text log", report.SampledBytes),
+			})
+		}
+		return 1
+	}
+
+	avg := report.SampledBytes / breaks
+	if avg > maxAvgLineBytes {
+		report.Issues = append(report.Issues, domain.IntegrityIssue{
+			Code:     domain.IntegrityLineLength,
+			Severity: domain.IntegrityWarning,
+			Message:  fmt.Sprintf("lines average %d bytes, far longer than AR log lines", avg),
+		})
+	}
+	return max(size/max(avg, 1), breaks)
+}
+
+// checkEdgeLines checks that the first and last non-empty lines look like
+// AR Server log lines and that the file ends with a complete line.
+func checkEdgeLines(windows []sampleWindow, size int64) []domain.IntegrityIssue {
+	var issues []domain.IntegrityIssue
+
+	head := windows[0]
+	data := head.data
+	if len(windows) > 1 || int64(len(data)) < size {
+		// The last line of the head window may be cut off.
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[:i+1]
+		}
+	}
+	seen := 0
+	firstOffset := int64(-1)
+	arLike := false
+	for pos := 0; pos < len(data) && seen < arProbeLines; {
+		line, next := nextLine(data, pos)
+		if len(bytes.TrimSpace(line)) > 0 {
+			if firstOffset < 0 {
+				firstOffset = head.offset + int64(pos)
+			}
+			seen++
+			if looksLikeARLine(line) {
+				arLike = true
+				break
+			}
+		}
+		pos = next
+	}
+	if seen == 0 && len(windows) == 1 {
+		return append(issues, domain.IntegrityIssue{
+			Code: domain.IntegrityEmptyFile, Severity: domain.IntegrityFatal, Message: "the file holds only whitespace",
+		})
+	}
+	if seen > 0 && !arLike {
+		issues = append(issues, domain.IntegrityIssue{
+			Code:     domain.IntegrityNotARLog,
+			Severity: domain.IntegrityWarning,
+			Offset:   &firstOffset,
+			Message:  fmt.Sprintf("none of the first %d lines look like AR Server log lines", seen),
+		})
+	}
+
+	tail := windows[len(windows)-1]
+	data = tail.data
+	start := 0
+	if tail.offset > 0 {
+		// Skip the partial line the tail window starts in.
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return issues
+		}
+		start = i + 1
+	}
+	endsWithNewline := len(data) > 0 && data[len(data)-1] == '\n'
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) <= start {
+		return issues
+	}
+	lineStart := start
+	if i := bytes.LastIndexByte(trimmed, '\n'); i >= start {
+		lineStart = i + 1
+	}
+	last := trimmed[lineStart:]
+	lastOffset := tail.offset + int64(lineStart)
+
+	switch {
+	case !endsWithNewline && !bytes.Contains(last, []byte("*/")):
+		issues = append(issues, domain.IntegrityIssue{
+			Code:     domain.IntegrityTruncated,
+			Severity: domain.IntegrityWarning,
+			Offset:   &lastOffset,
+			Message:  fmt.Sprintf("the file ends abruptly at byte %d, in a line cut off before its timestamp; the transfer may have been interrupted", size),
+		})
+	case !endsWithNewline:
+		issues = append(issues, domain.IntegrityIssue{
+			Code:     domain.IntegrityTruncated,
+			Severity: domain.IntegrityWarning,
+			Offset:   &lastOffset,
+			Message:  fmt.Sprintf("the last line, at byte %d, has no line break and may be incomplete", lastOffset),
+		})
+	case !looksLikeARLine(last):
+		issues = append(issues, domain.IntegrityIssue{
+			Code:     domain.IntegrityUnexpectedTail,
+			Severity: domain.IntegrityWarning,
+			Offset:   &lastOffset,
+			Message:  fmt.Sprintf("the last line, at byte %d, does not look like an AR Server log line", lastOffset),
+		})
+	}
+	return issues
+}
+
+// nextLine returns the line of data starting at pos, without its line
+// break, and the position of the following line.
+func nextLine(data []byte, pos int) ([]byte, int) {
+	end := bytes.IndexByte(data[pos:], '\n')
+	if end < 0 {
+		return data[pos:], len(data)
+	}
+	return bytes.TrimSuffix(data[pos:pos+end], []byte{'\r'}), pos + end + 1
+}
+
+func looksLikeARLine(line []byte) bool {
+	return bracketPrefixRegex.Match(line) || bannerLineRegex.Match(line)
+}
@@ -0,0 +1,129 @@
+package logparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func validate(t *testing.T, content []byte) *domain.FileIntegrity {
+	t.Helper()
+	report, err := ValidateLogFile(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	return report
+}
+
+func issue(report *domain.FileIntegrity, code string) *domain.IntegrityIssue {
+	for i := range report.Issues {
+		if report.Issues[i].Code == code {
+			return &report.Issues[i]
+		}
+	}
+	return nil
+}
+
+func TestValidateLogFile(t *testing.T) {
+	clean := sampleAPI + "\n" + sampleSQL + "\n"
+
+	cases := []struct {
+		name     string
+		content  string
+		code     string // "" for a clean report
+		severity domain.IntegritySeverity
+		offset   int64 // -1 when the issue carries no offset
+	}{
+		{"clean", clean, "", "", -1},
+		{"clean with CRLF line breaks", strings.ReplaceAll(clean, "\n", "\r\n"), "", "", -1},
+		{"banner before first line", "/* Mon Jan 05 2026 09:00:00.0000 */ Action Request System(R) Server\n" + clean, "", "", -1},
+		{"empty", "", domain.IntegrityEmptyFile, domain.IntegrityFatal, -1},
+		{"only whitespace", "\n \n\t\n", domain.IntegrityEmptyFile, domain.IntegrityFatal, -1},
+		{"truncated before timestamp", clean + sampleAPI[:40], domain.IntegrityTruncated, domain.IntegrityWarning, int64(len(clean))},
+		{"truncated after timestamp", clean + sampleAPI, domain.IntegrityTruncated, domain.IntegrityWarning, int64(len(clean))},
+		{"binary", clean + strings.Repeat("\x00\x01\x02\x03", 64) + "\n", domain.IntegrityBinaryContent, domain.IntegrityFatal, int64(len(clean))},
+		{"damaged region", strings.Repeat(clean, 200) + "\x00\x00" + clean, domain.IntegrityBinaryContent, domain.IntegrityWarning, int64(200 * len(clean))},
+		{"mixed encoding", clean + "<API > ... USER: Jos\xe9 ...\n" + clean, domain.IntegrityInvalidEncoding, domain.IntegrityWarning, int64(len(clean) + 20)},
+		{"not text", clean + strings.Repeat("\xff\xfe\xc0", 300) + "\n", domain.IntegrityInvalidEncoding, domain.IntegrityFatal, int64(len(clean))},
+		{"not an AR log", "2026-01-05 09:00:00 INFO started\n2026-01-05 09:00:01 INFO ready\n", domain.IntegrityNotARLog, domain.IntegrityWarning, 0},
+		{"unexpected tail", clean + "Exception in thread main\n", domain.IntegrityUnexpectedTail, domain.IntegrityWarning, int64(len(clean))},
+		{"no line breaks", strings.Repeat("x", 70<<10), domain.IntegrityNoLineBreaks, domain.IntegrityFatal, -1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := validate(t, []byte(tc.content))
+			assert.Equal(t, int64(len(tc.content)), report.SizeBytes)
+
+			if tc.code == "" {
+				assert.Empty(t, report.Issues)
+				assert.Nil(t, report.Fatal())
+				return
+			}
+
+			got := issue(report, tc.code)
+			require.NotNil(t, got, "issues: %+v", report.Issues)
+			assert.Equal(t, tc.severity, got.Severity)
+			assert.NotEmpty(t, got.Message)
+			if tc.offset < 0 {
+				assert.Nil(t, got.Offset)
+			} else {
+				require.NotNil(t, got.Offset)
+				assert.Equal(t, tc.offset, *got.Offset)
+			}
+			if tc.severity == domain.IntegrityFatal {
+				require.NotNil(t, report.Fatal())
+				assert.Equal(t, tc.code, report.Fatal().Code)
+			} else {
+				assert.Nil(t, report.Fatal())
+			}
+		})
+	}
+}
+
+func TestValidateLogFile_EstimatesLines(t *testing.T) {
+	line := sampleAPI + "\n"
+	content := strings.Repeat(line, 1000)
+
+	report := validate(t, []byte(content))
+	assert.Equal(t, int64(1000), report.EstimatedLines)
+	assert.Equal(t, report.SizeBytes, report.SampledBytes, "small files are read whole")
+}
+
+func TestValidateLogFile_SamplesLargeFiles(t *testing.T) {
+	line := sampleSQL + "\n"
+	content := []byte(strings.Repeat(line, 25_000)) // ~8 MiB
+	size := int64(len(content))
+
+	// A run of NULs in the middle of the file, where a sample window falls,
+	// as left by a disk that lost a block.
+	window := integrityEdgeBytes + (size-2*integrityEdgeBytes)*7/(integrityWindows+1)
+	hole := window - 100
+	copy(content[hole:], bytes.Repeat([]byte{0}, 200))
+
+	report := validate(t, content)
+	assert.Less(t, report.SampledBytes, size)
+	assert.InDelta(t, 25_000, report.EstimatedLines, 250)
+
+	got := issue(report, domain.IntegrityBinaryContent)
+	require.NotNil(t, got)
+	assert.Equal(t, domain.IntegrityWarning, got.Severity)
+	require.NotNil(t, got.Offset)
+	assert.GreaterOrEqual(t, *got.Offset, hole)
+	assert.Less(t, *got.Offset, hole+200)
+	assert.Nil(t, issue(report, domain.IntegrityTruncated))
+}
+
+func TestValidateLogFile_LargeTruncatedFile(t *testing.T) {
+	line := sampleSQL + "\n"
+	content := strings.Repeat(line, 25_000) + sampleSQL[:60]
+
+	report := validate(t, []byte(content))
+	got := issue(report, domain.IntegrityTruncated)
+	require.NotNil(t, got)
+	require.NotNil(t, got.Offset)
+	assert.Equal(t, int64(25_000*len(line)), *got.Offset)
+}
@@ -3,6 +3,8 @@ package logparser
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"os"
@@ -17,9 +19,10 @@ import (
 
 // lineRegex matches the angle-bracket AR Server log format:
 // <TYPE> <TrID: V> <TID: V> <RPC ID: V> <Queue: V> <Client-RPC: V> <USER: V> <Overlay-Group: V> /* timestamp */ content
+// The TrID field is absent in logs written by AR Server 9.x.
 var lineRegex = regexp.MustCompile(
 	`^<(\w{3,4})\s*>\s*` +
-		`<TrID:\s*([^>]+?)>\s*` +
+		`(?:<TrID:\s*([^>]+?)>\s*)?` +
 		`<TID:\s*(\d+)\s*>\s*` +
 		`<RPC ID:\s*(\d+)\s*>\s*` +
 		`<Queue:\s*([^>]+?)>\s*` +
@@ -45,16 +48,36 @@ var filterNameRegex = regexp.MustCompile(`(?:Checking\s+"([^"]+)"|Filter:\s*(\S+
 var filterOperationRegex = regexp.MustCompile(`Operation\s*-\s*(\w+)\s+on\s+(\S+)`)
 
 // durationSecsRegex extracts timing in seconds from content like "run time 0.123 secs" or "(0.456 secs)".
-var durationSecsRegex = regexp.MustCompile(`(\d+\.?\d*)\s*secs?\b`)
+var durationSecsRegex = regexp.MustCompile(`(-?\d+\.?\d*)\s*secs?\b`)
 
 // durationMSRegex extracts timing in milliseconds.
-var durationMSRegex = regexp.MustCompile(`(\d+\.?\d*)\s*ms\b`)
+var durationMSRegex = regexp.MustCompile(`(-?\d+\.?\d*)\s*ms\b`)
 
 // elapsedRegex extracts "elapsed N.NNN" patterns.
-var elapsedRegex = regexp.MustCompile(`(?i)elapsed\s*[:=]?\s*(\d+\.?\d*)`)
+var elapsedRegex = regexp.MustCompile(`(?i)elapsed\s*[:=]?\s*(-?\d+\.?\d*)`)
 
 // sqlRowsTimeRegex extracts SQL result timing like "OK (nnn rows nn.nnn secs)".
-var sqlRowsTimeRegex = regexp.MustCompile(`(?i)OK\s*\(\s*\d+\s*rows?\s+(\d+\.?\d*)\s*secs?\s*\)`)
+var sqlRowsTimeRegex = regexp.MustCompile(`(?i)OK\s*\(\s*\d+\s*rows?\s+(-?\d+\.?\d*)\s*secs?\s*\)`)
+
+// clientRegex extracts the client program and address AR Server appends to
+// the start line of an API call, e.g.
+// "+GE ARGetEntry -- schema HPD:Help Desk from Mid-tier (protocol 26) at IP address 10.1.2.3".
+// Calls made inside the server have no address.
+var clientRegex = regexp.MustCompile(`\sfrom\s+(.+?)\s+\(protocol\s+[^)]*\)(?:\s+at\s+IP\s+address\s+([^\s,;]+))?`)
+
+// entryIDNamespace is the UUIDv5 namespace for log entry IDs.
+var entryIDNamespace = uuid.MustParse("6f1c9b52-3a8e-5d47-9b1e-2c0f4a7d8e31")
+
+// EntryID derives the stable identifier of a log entry. The ID is a UUIDv5
+// (SHA-1, entryIDNamespace) over "jobID/fileNumber/lineNumber/sha256(rawLine)",
+// so re-ingesting the same file yields the same IDs, while a line whose content
+// changed gets a new one. Shared links and references to entries therefore
+// survive reprocessing.
+func EntryID(jobID string, fileNumber uint16, lineNumber uint32, rawLine string) string {
+	sum := sha256.Sum256([]byte(rawLine))
+	name := fmt.Sprintf("%s/%d/%d/%s", jobID, fileNumber, lineNumber, hex.EncodeToString(sum[:]))
+	return uuid.NewSHA1(entryIDNamespace, []byte(name)).String()
+}
 
 // ParseLine parses a single angle-bracket formatted AR Server log line into a LogEntry.
 // Returns nil, error if the line doesn't match the expected format.
@@ -80,11 +103,11 @@ func ParseLine(line string, lineNum uint32, tenantID, jobID string) (*domain.Log
 	entry := &domain.LogEntry{
 		TenantID:   tenantID,
 		JobID:      jobID,
-		EntryID:    uuid.New().String(),
+		EntryID:    EntryID(jobID, 1, lineNum, line),
 		LineNumber: lineNum,
 		FileNumber: 1,
-		Timestamp:  ts,
-		IngestedAt: time.Now().UTC(),
+		Timestamp:  domain.NewTimestamp(ts),
+		IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 		LogType:    logType,
 		TraceID:    strings.TrimSpace(matches[2]),
 		RPCID:      strings.TrimSpace(matches[4]),
@@ -113,6 +136,50 @@ func ParseLine(line string, lineNum uint32, tenantID, jobID string) (*domain.Log
 // Lines that don't match the expected format are silently skipped.
 // Returns the total number of successfully parsed entries.
 func ParseFile(ctx context.Context, filePath string, tenantID, jobID string, batchSize int, callback func([]domain.LogEntry) error) (int64, error) {
+	return ParseCapture(ctx, filePath, tenantID, jobID, nil, batchSize, callback)
+}
+
+// segmentTolerance is how far a timestamp may run backwards, or past the
+// end of the current file, before ParseCapture treats it as the start of the
+// next captured file.
+const segmentTolerance = time.Second
+
+// fileSegmenter assigns entries of a concatenated capture to the files the
+// JAR reported. Each AR log file is written in time order, so a timestamp
+// that jumps backwards, or beyond the current file's end, starts the next file.
+type fileSegmenter struct {
+	files []domain.FileMetadata
+	idx   int
+	prev  time.Time
+}
+
+func (s *fileSegmenter) fileNumber(ts time.Time) uint16 {
+	if len(s.files) == 0 {
+		return 1
+	}
+	if !s.prev.IsZero() && s.idx+1 < len(s.files) {
+		end := s.files[s.idx].EndTime
+		if ts.Before(s.prev.Add(-segmentTolerance)) || (!end.IsZero() && ts.After(end.Add(segmentTolerance))) {
+			s.idx++
+		}
+	}
+	s.prev = ts
+	if n := s.files[s.idx].FileNumber; n > 0 {
+		return uint16(n)
+	}
+	return uint16(s.idx + 1)
+}
+
+// ParseCapture is ParseFile for an upload that concatenates several AR log
+// files, listed in files as reported by the JAR. Entries carry the number of
+// the file they came from; line numbers stay relative to the upload. With
+// fewer than two files every entry belongs to file 1.
+func ParseCapture(ctx context.Context, filePath string, tenantID, jobID string, files []domain.FileMetadata, batchSize int, callback func([]domain.LogEntry) error) (int64, error) {
+	segmenter := &fileSegmenter{}
+	if len(files) > 1 {
+		segmenter.files = files
+	}
+
 	f, err := os.Open(filePath)
 	if err != nil {
 		return 0, fmt.Errorf("open file: %w", err)
@@ -149,6 +216,10 @@ func ParseFile(ctx context.Context, filePath string, tenantID, jobID string, bat
 			// Skip malformed lines (continuation lines, headers, etc.)
 			continue
 		}
+		if n := segmenter.fileNumber(entry.Timestamp.Time); n != entry.FileNumber {
+			entry.FileNumber = n
+			entry.EntryID = EntryID(jobID, n, lineNum, line)
+		}
 
 		batch = append(batch, *entry)
 
@@ -200,34 +271,47 @@ func cleanUser(user string) string {
 	return user
 }
 
+// setDuration sets the DurationMS of entry to ms rounded to the
+// millisecond. A clock adjustment during a call can make its logged
+// duration negative; such a duration is clamped to zero and the entry
+// flagged, so that ingestion can count them.
+func setDuration(entry *domain.LogEntry, ms float64) {
+	if ms < 0 {
+		entry.DurationMS = 0
+		entry.DurationClamped = true
+		return
+	}
+	entry.DurationMS = uint32(math.Round(ms))
+}
+
 // extractDuration tries to find timing information in log content and sets DurationMS.
 // Returns true if a duration was extracted.
 func extractDuration(entry *domain.LogEntry, content string) bool {
 	// Try SQL result pattern first: "OK (123 rows 0.456 secs)"
 	if m := sqlRowsTimeRegex.FindStringSubmatch(content); m != nil {
 		if secs, err := strconv.ParseFloat(m[1], 64); err == nil {
-			entry.DurationMS = uint32(math.Round(secs * 1000))
+			setDuration(entry, secs*1000)
 			return true
 		}
 	}
 	// Try generic seconds pattern: "0.123 secs"
 	if m := durationSecsRegex.FindStringSubmatch(content); m != nil {
 		if secs, err := strconv.ParseFloat(m[1], 64); err == nil {
-			entry.DurationMS = uint32(math.Round(secs * 1000))
+			setDuration(entry, secs*1000)
 			return true
 		}
 	}
 	// Try milliseconds pattern: "123 ms"
 	if m := durationMSRegex.FindStringSubmatch(content); m != nil {
 		if ms, err := strconv.ParseFloat(m[1], 64); err == nil {
-			entry.DurationMS = uint32(math.Round(ms))
+			setDuration(entry, ms)
 			return true
 		}
 	}
 	// Try elapsed pattern: "elapsed 0.123"
 	if m := elapsedRegex.FindStringSubmatch(content); m != nil {
 		if secs, err := strconv.ParseFloat(m[1], 64); err == nil {
-			entry.DurationMS = uint32(math.Round(secs * 1000))
+			setDuration(entry, secs*1000)
 			return true
 		}
 	}
@@ -277,11 +361,39 @@ func parseEscalation(entry *domain.LogEntry, content string) {
 	extractDuration(entry, content)
 }
 
+// extractClient returns the client and client address of an API start line
+// and the offset at which their suffix begins. ok is false when the line
+// names no client.
+func extractClient(content string) (client, ip string, at int, ok bool) {
+	m := clientRegex.FindStringSubmatchIndex(content)
+	if m == nil {
+		return "", "", 0, false
+	}
+	at = m[0]
+	client = content[m[2]:m[3]]
+	// A form name may itself contain " from "; the client follows the last one.
+	if i := strings.LastIndex(client, " from "); i >= 0 {
+		at = m[2] + i
+		client = client[i+len(" from "):]
+	}
+	if m[4] >= 0 {
+		ip = content[m[4]:m[5]]
+	}
+	return strings.TrimSpace(client), ip, at, true
+}
+
 // parseAPI extracts API-specific fields from the log content.
 func parseAPI(entry *domain.LogEntry, content string) {
+	head := content
+	if client, ip, at, ok := extractClient(content); ok {
+		entry.Client = client
+		entry.ClientIP = ip
+		head = content[:at]
+	}
+
 	// API entries contain operation codes and form names.
 	// Example content: "GE HPD:Help Desk ..."
-	parts := strings.Fields(content)
+	parts := strings.Fields(head)
 	if len(parts) >= 1 {
 		entry.APICode = parts[0]
 	}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -48,7 +50,7 @@ func TestParseLine_ESCL(t *testing.T) {
 
 	// Timestamp.
 	expected := time.Date(2025, 11, 24, 14, 46, 58, 505000000, time.UTC)
-	assert.Equal(t, expected, entry.Timestamp)
+	assert.Equal(t, expected, entry.Timestamp.Time)
 
 	// Escalation-specific.
 	assert.Equal(t, "Survey Submitter", entry.EscName)
@@ -79,6 +81,29 @@ func TestParseLine_SQL_OK(t *testing.T) {
 	assert.Empty(t, entry.SQLTable) // No table for OK responses.
 }
 
+func TestParseLine_SQL_Duration(t *testing.T) {
+	prefix := `<SQL > <TrID: abc:001> <TID: 0000000001> <RPC ID: 0000000001> <Queue: Fast> <Client-RPC: 100> <USER: Admin> <Overlay-Group: 1> /* Mon Nov 24 2025 14:46:58.5090 */ `
+	tests := []struct {
+		name    string
+		content string
+		want    uint32
+		clamped bool
+	}{
+		{"timed", "OK (12 rows 0.250 secs)", 250, false},
+		{"below resolution", "OK (1 rows 0.0004 secs)", 0, false},
+		{"negative after a clock adjustment", "OK (3 rows -0.120 secs)", 0, true},
+		{"negative in ms", "COMMIT -15 ms", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := ParseLine(prefix+tt.content, 1, testTenantID, testJobID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, entry.DurationMS)
+			assert.Equal(t, tt.clamped, entry.DurationClamped)
+		})
+	}
+}
+
 func TestParseLine_SQL_CommitBegin(t *testing.T) {
 	commitLine := `<SQL > <TrID: abc:001> <TID: 0000000001> <RPC ID: 0000000001> <Queue: Fast> <Client-RPC: 100> <USER: Admin> <Overlay-Group: 1> /* Mon Nov 24 2025 14:46:58.5100 */ COMMIT TRANSACTION`
 	entry, err := ParseLine(commitLine, 14, testTenantID, testJobID)
@@ -144,7 +169,86 @@ func TestParseLine_API(t *testing.T) {
 
 	// Timestamp.
 	expected := time.Date(2025, 12, 2, 9, 30, 15, 123400000, time.UTC)
-	assert.Equal(t, expected, entry.Timestamp)
+	assert.Equal(t, expected, entry.Timestamp.Time)
+}
+
+func TestParseLine_APIClient(t *testing.T) {
+	const prefix = `<API > <TrID: oKNmA5MvSwOxCzBulz9-zQ:0002868> <TID: 0000000532> <RPC ID: 0000015447> <Queue: Fast        > <Client-RPC: 390620   > <USER: Demo                                         > <Overlay-Group: 1         > /* Mon Nov 24 2025 14:46:58.5010 */ `
+	tests := []struct {
+		name, content    string
+		apiCode, form    string
+		client, clientIP string
+	}{
+		{
+			name:     "mid-tier",
+			content:  "+GE    ARGetEntry -- schema HPD:Help Desk from Mid-tier (protocol 26) at IP address 10.1.2.3",
+			apiCode:  "+GE",
+			form:     "ARGetEntry -- schema HPD:Help Desk",
+			client:   "Mid-tier",
+			clientIP: "10.1.2.3",
+		},
+		{
+			name:     "trailing rpc details",
+			content:  "+GLEWF  ARGetListEntryWithFields -- schema SYS:Notification Messages from Approval Server (protocol 26) at IP address 10.133.136.35 using RPC // :q:0.0s",
+			apiCode:  "+GLEWF",
+			form:     "ARGetListEntryWithFields -- schema SYS:Notification Messages",
+			client:   "Approval Server",
+			clientIP: "10.133.136.35",
+		},
+		{
+			name:     "unidentified client",
+			content:  "+GSI    ARGetServerInfo -- from Unidentified Client (protocol 26) at IP address 10.11.19.2",
+			apiCode:  "+GSI",
+			form:     "ARGetServerInfo --",
+			client:   "Unidentified Client",
+			clientIP: "10.11.19.2",
+		},
+		{
+			name:    "no address",
+			content: "+SE    ARSetEntry -- schema HPD:Help Desk from Assignment Engine (protocol 26)",
+			apiCode: "+SE",
+			form:    "ARSetEntry -- schema HPD:Help Desk",
+			client:  "Assignment Engine",
+		},
+		{
+			name:     "form name containing from",
+			content:  "+GLE   ARGetListEntry -- schema CTM:People from LDAP from Mid-tier (protocol 26) at IP address 10.1.2.3",
+			apiCode:  "+GLE",
+			form:     "ARGetListEntry -- schema CTM:People from LDAP",
+			client:   "Mid-tier",
+			clientIP: "10.1.2.3",
+		},
+		{
+			name:    "end line",
+			content: "-GE             OK",
+			apiCode: "-GE",
+			form:    "OK",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := ParseLine(prefix+tt.content, 1, testTenantID, testJobID)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.apiCode, entry.APICode)
+			assert.Equal(t, tt.form, entry.Form)
+			assert.Equal(t, tt.client, entry.Client)
+			assert.Equal(t, tt.clientIP, entry.ClientIP)
+		})
+	}
+}
+
+func TestParseLine_LegacyWithoutTrID(t *testing.T) {
+	line := `<SQL > <TID: 0000000212> <RPC ID: 0000004411> <Queue: Fast      > <Client-RPC: 390620   > <USER: Demo                 > <Overlay-Group: 1         > /* Mon Feb 02 2026 08:00:01.1040 */ SELECT T2115.C1 FROM T2115 WHERE (T2115.C1 = 'INC000000000101')`
+	entry, err := ParseLine(line, 7, testTenantID, testJobID)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	assert.Equal(t, domain.LogTypeSQL, entry.LogType)
+	assert.Empty(t, entry.TraceID)
+	assert.Equal(t, "0000000212", entry.ThreadID)
+	assert.Equal(t, "Demo", entry.User)
+	assert.Equal(t, "T2115", entry.SQLTable)
 }
 
 func TestParseLine_MalformedLine(t *testing.T) {
@@ -179,7 +283,7 @@ func TestParseLine_TimestampParsing(t *testing.T) {
 	line := `<SQL > <TrID: t:1> <TID: 0000000001> <RPC ID: 0000000001> <Queue: Q> <Client-RPC: 1> <USER: U> <Overlay-Group: 1> /* Wed Jan 01 2025 00:00:00.0000 */ OK`
 	entry, err := ParseLine(line, 1, testTenantID, testJobID)
 	require.NoError(t, err)
-	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), entry.Timestamp)
+	assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), entry.Timestamp.Time)
 }
 
 func TestParseFile_BatchCallback(t *testing.T) {
@@ -216,6 +320,108 @@ func TestParseFile_BatchCallback(t *testing.T) {
 	assert.Equal(t, []int{3, 3, 1}, batches)
 }
 
+func TestParseCapture_AssignsFileNumbers(t *testing.T) {
+	at := func(clock string) string {
+		return strings.Replace(sampleAPI, "09:30:15.1234", clock, 1)
+	}
+	parseTS := func(clock string) time.Time {
+		ts, err := time.Parse(arTimestampLayout, "Tue Dec 02 2025 "+clock)
+		require.NoError(t, err)
+		return ts
+	}
+
+	// Three files: the second overlaps the first in time (its clock runs
+	// behind), the third starts after the second ended.
+	lines := []string{
+		at("09:00:00.0000"), at("09:10:00.0000"), at("09:20:00.0000"),
+		at("08:58:00.0000"), at("09:08:00.0000"),
+		at("10:00:00.0000"), at("10:05:00.0000"),
+	}
+	files := []domain.FileMetadata{
+		{FileNumber: 1, StartTime: domain.NewTimestamp(parseTS("09:00:00.0000")), EndTime: domain.NewTimestamp(parseTS("09:20:00.0000"))},
+		{FileNumber: 2, StartTime: domain.NewTimestamp(parseTS("08:58:00.0000")), EndTime: domain.NewTimestamp(parseTS("09:08:00.0000"))},
+		{FileNumber: 3, StartTime: domain.NewTimestamp(parseTS("10:00:00.0000")), EndTime: domain.NewTimestamp(parseTS("10:05:00.0000"))},
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	var got []uint16
+	ids := make(map[string]bool)
+	_, err := ParseCapture(context.Background(), path, testTenantID, testJobID, files, 10, func(batch []domain.LogEntry) error {
+		for _, e := range batch {
+			got = append(got, e.FileNumber)
+			ids[e.EntryID] = true
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{1, 1, 1, 2, 2, 3, 3}, got)
+	assert.Len(t, ids, len(lines))
+
+	// Without a multi-file listing everything stays in file 1.
+	got = nil
+	_, err = ParseCapture(context.Background(), path, testTenantID, testJobID, files[:1], 10, func(batch []domain.LogEntry) error {
+		for _, e := range batch {
+			got = append(got, e.FileNumber)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{1, 1, 1, 1, 1, 1, 1}, got)
+}
+
+// collectEntryIDs parses the file at path and returns its entry IDs keyed by line number.
+func collectEntryIDs(t *testing.T, path, jobID string) map[uint32]string {
+	t.Helper()
+	ids := make(map[uint32]string)
+	_, err := ParseFile(context.Background(), path, testTenantID, jobID, 2, func(batch []domain.LogEntry) error {
+		for _, e := range batch {
+			ids[e.LineNumber] = e.EntryID
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return ids
+}
+
+func TestParseFile_EntryIDsStableAcrossIngestions(t *testing.T) {
+	path := filepath.Join("..", "..", "testdata", "ar25_sample.log")
+
+	first := collectEntryIDs(t, path, testJobID)
+	second := collectEntryIDs(t, path, testJobID)
+
+	require.Len(t, first, 5)
+	assert.Equal(t, first, second)
+}
+
+func TestParseFile_EntryIDsDivergeWhenContentDiffers(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "original.log")
+	edited := filepath.Join(dir, "edited.log")
+	require.NoError(t, os.WriteFile(original, []byte(sampleSQL+"\n"+sampleAPI+"\n"), 0644))
+	require.NoError(t, os.WriteFile(edited, []byte(sampleSQL+"\n"+strings.Replace(sampleAPI, "HPD:Help Desk", "CHG:Change", 1)+"\n"), 0644))
+
+	a := collectEntryIDs(t, original, testJobID)
+	b := collectEntryIDs(t, edited, testJobID)
+
+	assert.Equal(t, a[1], b[1], "unchanged line keeps its ID")
+	assert.NotEqual(t, a[2], b[2], "edited line gets a new ID")
+	assert.NotEqual(t, a[1], collectEntryIDs(t, original, "job-002")[1], "IDs are scoped to the job")
+}
+
+func TestEntryID_Deterministic(t *testing.T) {
+	id := EntryID(testJobID, 1, 42, sampleSQL)
+	assert.Equal(t, id, EntryID(testJobID, 1, 42, sampleSQL))
+	assert.NotEqual(t, id, EntryID(testJobID, 2, 42, sampleSQL))
+	assert.NotEqual(t, id, EntryID(testJobID, 1, 43, sampleSQL))
+
+	parsed, err := uuid.Parse(id)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(5), parsed.Version())
+}
+
 func TestParseFile_EmptyFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "empty.log")
@@ -0,0 +1,96 @@
+package logparser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var (
+	// sqlLiteralRegex matches string literals, with '' escapes and the N
+	// prefix of national strings, so that their contents are not taken for
+	// SQL.
+	sqlLiteralRegex = regexp.MustCompile(`(?i)N?'(?:[^']|'')*'`)
+
+	// sqlLeadingNoiseRegex matches comments and opening parentheses before
+	// the first keyword of a statement.
+	sqlLeadingNoiseRegex = regexp.MustCompile(`^(?s:\s+|/\*.*?\*/|--[^\n]*\n?|\()*`)
+
+	sqlKeywordRegex = regexp.MustCompile(`^[A-Za-z]+`)
+
+	sqlWhereRegex = regexp.MustCompile(`(?i)\bWHERE\b`)
+
+	// sqlWhereEndRegex matches what ends a WHERE clause.
+	sqlWhereEndRegex = regexp.MustCompile(`(?i)\b(?:WHERE|ORDER\s+BY|GROUP\s+BY|HAVING|UNION|FETCH\s+FIRST|FOR\s+UPDATE)\b`)
+
+	sqlFromRegex = regexp.MustCompile(`(?i)\bFROM\s+"?(\w+)`)
+
+	// sqlKeyColumnRegex matches the Request ID column, C1, as AR names it
+	// in its own tables ("T4381.C1", "C1") and in its views ("EN1").
+	sqlKeyColumnRegex = regexp.MustCompile(`(?i)(?:^|[^\w$])"?(?:C1|EN1)"?(?:[^\w$]|$)`)
+)
+
+// SQLOperation returns the operation of a statement from its leading
+// keyword, past comments and parentheses: one of the domain.SQLOperation
+// constants. Common table expressions (WITH ...) are AR's way of writing
+// selects and count as SELECT.
+func SQLOperation(stmt string) string {
+	s := sqlLeadingNoiseRegex.ReplaceAllString(stmt, "")
+	switch strings.ToUpper(sqlKeywordRegex.FindString(s)) {
+	case "SELECT", "WITH":
+		return domain.SQLOperationSelect
+	case "INSERT":
+		return domain.SQLOperationInsert
+	case "UPDATE":
+		return domain.SQLOperationUpdate
+	case "DELETE":
+		return domain.SQLOperationDelete
+	}
+	return domain.SQLOperationOther
+}
+
+// SQLScanSuspicion returns why a statement may scan its whole table, or ""
+// when it does not look like it would. It is a heuristic on the statement
+// text alone, without the indexes of the database:
+//
+//   - A SELECT, UPDATE or DELETE with no WHERE clause reads every row, and
+//     is reported as domain.SQLScanNoWhere. Selects without a table, such
+//     as those FROM DUAL, are not.
+//   - One whose WHERE clauses never mention the Request ID (C1, or EN1 in
+//     AR's views) is reported as domain.SQLScanNonKeyFilter. C1 is the only
+//     column AR indexes on every form, so such a filter is served by an
+//     index only if an administrator added one; the caller decides whether
+//     the statement is slow enough for that to be doubtful.
+//
+// String literals are ignored, so that a WHERE inside a value does not
+// count. INSERTs and other statements are never suspected.
+func SQLScanSuspicion(stmt string) domain.SQLScanReason {
+	op := SQLOperation(stmt)
+	if op != domain.SQLOperationSelect && op != domain.SQLOperationUpdate && op != domain.SQLOperationDelete {
+		return ""
+	}
+	s := sqlLiteralRegex.ReplaceAllString(stmt, "?")
+
+	if op == domain.SQLOperationSelect {
+		m := sqlFromRegex.FindStringSubmatch(s)
+		if m == nil || strings.EqualFold(m[1], "DUAL") {
+			return ""
+		}
+	}
+
+	wheres := sqlWhereRegex.FindAllStringIndex(s, -1)
+	if len(wheres) == 0 {
+		return domain.SQLScanNoWhere
+	}
+	for _, loc := range wheres {
+		clause := s[loc[1]:]
+		if end := sqlWhereEndRegex.FindStringIndex(clause); end != nil {
+			clause = clause[:end[0]]
+		}
+		if sqlKeyColumnRegex.MatchString(clause) {
+			return ""
+		}
+	}
+	return domain.SQLScanNonKeyFilter
+}
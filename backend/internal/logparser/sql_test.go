@@ -0,0 +1,157 @@
+package logparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestSQLOperation(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string
+	}{
+		{`SELECT T4381.C1 FROM T4381 WHERE (T4381.C1 = N'000000000003816')`, domain.SQLOperationSelect},
+		{`select count(*) from T24`, domain.SQLOperationSelect},
+		{`WITH AR_SQL_Alias$1 AS (SELECT T4381.C1 FROM T4381 WHERE T4381.C7 = 0) SELECT C1 FROM AR_SQL_Alias$1`, domain.SQLOperationSelect},
+		{`(SELECT "EN1" FROM "T00082") UNION (SELECT "EN1" FROM "T00083")`, domain.SQLOperationSelect},
+		{`/* AR 25.1 */ SELECT T24.C1 FROM T24`, domain.SQLOperationSelect},
+		{"-- escalation\nSELECT\n  T24.C1\nFROM T24", domain.SQLOperationSelect},
+		{`INSERT INTO T4381 (C1,C2,C3,C4,C5,C6,C7,C8) VALUES (N'000000000003817',N'Demo',1763995618,N'Demo',N'Demo',1763995618,0,N'Escalation log')`, domain.SQLOperationInsert},
+		{`UPDATE T4381 SET T4381.C536870913 = N'50836' WHERE T4381.C1 = N'000000000003816'`, domain.SQLOperationUpdate},
+		{`DELETE FROM "T00720" WHERE ("EN1" = ? AND "C7" = 0)`, domain.SQLOperationDelete},
+		{`COMMIT TRANSACTION`, domain.SQLOperationOther},
+		{`OK`, domain.SQLOperationOther},
+		{`MERGE INTO T24 USING DUAL ON (C1 = ?)`, domain.SQLOperationOther},
+		{``, domain.SQLOperationOther},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, SQLOperation(tc.stmt), tc.stmt)
+	}
+}
+
+func TestSQLScanSuspicion(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want domain.SQLScanReason
+	}{
+		// Statements AR generates for the usual operations on one request.
+		{
+			name: "get entry",
+			stmt: `SELECT T4381.C1,C2,C3,C4,C5,C6,C7,C8,C536870913 FROM T4381 WHERE (T4381.C1 = N'000000000003816')`,
+		},
+		{
+			name: "set entry",
+			stmt: `UPDATE T4381 SET T4381.C536870913 = N'50836',C6 = 1763995627 WHERE T4381.C1 = N'000000000003816'`,
+		},
+		{
+			name: "delete entry",
+			stmt: `DELETE FROM T4381 WHERE T4381.C1 = N'000000000003816'`,
+		},
+		{
+			name: "view form quoting",
+			stmt: `SELECT "EN1" AS "C0","EN2" AS "C1","EN3" AS "C2","EN7" AS "C3" FROM "T00001" WHERE ("EN1" = ?)`,
+		},
+		{
+			name: "C1 among other conditions",
+			stmt: `SELECT T4381.C1 FROM T4381 WHERE ((T4381.C7 = 0) AND (T4381.C1 > N'000000000003700')) ORDER BY 1 ASC`,
+		},
+		{
+			name: "C1 in the common table expression",
+			stmt: `WITH AR_SQL_Alias$1 AS (SELECT T4381.C1 FROM T4381 WHERE (T4381.C1 = N'000000000003816')) SELECT AR_SQL_Alias$1.C1 FROM AR_SQL_Alias$1`,
+		},
+		{
+			name: "C1 in a subquery",
+			stmt: `SELECT T338.C1 FROM T338 WHERE T338.C536870920 IN (SELECT T4381.C536870913 FROM T4381 WHERE T4381.C1 = N'000000000003816')`,
+		},
+
+		// Statements without a WHERE clause.
+		{
+			name: "list all requests",
+			stmt: `SELECT T4381.C1 FROM T4381`,
+			want: domain.SQLScanNoWhere,
+		},
+		{
+			name: "count all requests",
+			stmt: `SELECT COUNT(*) FROM T24`,
+			want: domain.SQLScanNoWhere,
+		},
+		{
+			name: "update every request",
+			stmt: `UPDATE T4381 SET C7 = 1`,
+			want: domain.SQLScanNoWhere,
+		},
+		{
+			name: "purge a form",
+			stmt: `DELETE FROM "T00720"`,
+			want: domain.SQLScanNoWhere,
+		},
+		{
+			name: "WHERE inside a literal",
+			stmt: `UPDATE T4381 SET C536870913 = N'see WHERE T4381.C1 = 1'`,
+			want: domain.SQLScanNoWhere,
+		},
+		{
+			name: "ordered list",
+			stmt: `SELECT "EN1" AS "C0" FROM "T00082" ORDER BY "C3" DESC`,
+			want: domain.SQLScanNoWhere,
+		},
+
+		// Statements filtered on other columns.
+		{
+			name: "qualification on a character field",
+			stmt: `SELECT "EN1" AS "C0","EN2" AS "C1" FROM "T00045" WHERE ("C536870913" = ? AND "C7" = 0) ORDER BY "C6" DESC`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "leading wildcard LIKE",
+			stmt: `SELECT T4381.C1 FROM T4381 WHERE (T4381.C536870914 LIKE N'%server%')`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "escalation qualification",
+			stmt: `SELECT "EN1" AS "C0" FROM "T00720" WHERE ("C7" = 0 AND "C536870913" < ?)`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "update on status",
+			stmt: `UPDATE T4381 SET C7 = 2 WHERE T4381.C7 = 1 AND T4381.C6 < 1763995618`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "C1 only in the select list",
+			stmt: `SELECT T4381.C1 FROM T4381 WHERE (T4381.C3 > 1763995618) ORDER BY T4381.C1 ASC`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "C1 only in a literal",
+			stmt: `SELECT T4381.C1 FROM T4381 WHERE (T4381.C8 = N'C1')`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "columns that start with C1",
+			stmt: `SELECT T4381.C1 FROM T4381 WHERE (T4381.C15 = 0 AND T4381.C112 LIKE N'%;1000000001;%')`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+		{
+			name: "join on form fields",
+			stmt: `SELECT T338.C1 FROM T338, T4381 WHERE T338.C536870920 = T4381.C536870913 AND T4381.C7 = 0`,
+			want: domain.SQLScanNonKeyFilter,
+		},
+
+		// Statements never suspected.
+		{name: "insert", stmt: `INSERT INTO "T00001" ("C0","C1","C2","C3","C7","C8") VALUES (?,?,?,?,?,?)`},
+		{name: "next ID", stmt: `SELECT NEXTID_SEQ.NEXTVAL FROM DUAL`},
+		{name: "select without table", stmt: `SELECT 1`},
+		{name: "commit", stmt: `COMMIT TRANSACTION`},
+		{name: "OK", stmt: `OK`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SQLScanSuspicion(tc.stmt))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package logsource
+
+import (
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// gcLineRegex splits a unified JVM logging line (-Xlog:gc) into its
+// decorators and message:
+//
+//	[2025-10-10T13:55:36.123+0000][12.345s][info][gc] GC(42) Pause Young (Normal) (G1 Evacuation Pause) 512M->128M(2048M) 35.123ms
+var gcLineRegex = regexp.MustCompile(`^((?:\[[^\]]*\])+)\s*(.*)$`)
+
+// gcPauseRegex matches the message of a stop-the-world pause, with the heap
+// before, after and in total when the collector logs it.
+var gcPauseRegex = regexp.MustCompile(`^GC\((\d+)\) (Pause .*?)(?: \d+[KMGT]?->\d+[KMGT]?\(\d+[KMGT]?\))? (\d+(?:\.\d+)?)ms$`)
+
+// gcTimeLayouts are the layouts of the time and utctime decorators.
+var gcTimeLayouts = []string{"2006-01-02T15:04:05.000-0700", "2006-01-02T15:04:05.000Z0700"}
+
+// JVMGC parses unified JVM GC logs (JDK 9 and later). Each stop-the-world
+// pause is a GC entry starting when the pause did, with the pause as its
+// duration, the pause description as its operation and the GC cycle, e.g.
+// "GC(42)", as its trace ID. Concurrent phases do not stall the
+// application and are skipped, as are lines without a wall clock
+// decorator, which cannot be placed on a timeline.
+type JVMGC struct{}
+
+func (JVMGC) Detect(header []byte) bool {
+	return detectLines(header, func(line string) bool {
+		m := gcLineRegex.FindStringSubmatch(line)
+		return m != nil && gcHasTag(m[1])
+	})
+}
+
+func (JVMGC) Parse(r io.Reader, emit func(domain.LogEntry)) error {
+	return scanLines(r, func(line string, lineNumber uint32) {
+		if e, ok := parseGCLine(line); ok {
+			e.LineNumber = lineNumber
+			emit(e)
+		}
+	})
+}
+
+func parseGCLine(line string) (domain.LogEntry, bool) {
+	m := gcLineRegex.FindStringSubmatch(line)
+	if m == nil || !gcHasTag(m[1]) {
+		return domain.LogEntry{}, false
+	}
+	logged, ok := gcWallTime(m[1])
+	if !ok {
+		return domain.LogEntry{}, false
+	}
+	p := gcPauseRegex.FindStringSubmatch(m[2])
+	if p == nil {
+		return domain.LogEntry{}, false
+	}
+	ms, err := strconv.ParseFloat(p[3], 64)
+	if err != nil {
+		return domain.LogEntry{}, false
+	}
+
+	// The pause is logged once it is over.
+	pause := time.Duration(ms * float64(time.Millisecond))
+	return domain.LogEntry{
+		Timestamp:  domain.NewTimestamp(logged.Add(-pause)),
+		LogType:    domain.LogTypeGC,
+		TraceID:    "GC(" + p[1] + ")",
+		Operation:  p[2],
+		DurationMS: uint32(math.Round(ms)),
+		Success:    true,
+		RawText:    line,
+	}, true
+}
+
+// gcDecorators returns the decorators of "[a][b][c]".
+func gcDecorators(s string) []string {
+	return strings.Split(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), "][")
+}
+
+// gcHasTag reports whether the tag set decorator holds the gc tag.
+func gcHasTag(decorators string) bool {
+	for _, d := range gcDecorators(decorators) {
+		for _, tag := range strings.Split(d, ",") {
+			if strings.TrimSpace(tag) == "gc" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func gcWallTime(decorators string) (time.Time, bool) {
+	for _, d := range gcDecorators(decorators) {
+		for _, layout := range gcTimeLayouts {
+			if t, err := time.Parse(layout, d); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
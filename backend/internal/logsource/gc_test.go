@@ -0,0 +1,56 @@
+package logsource
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestJVMGC_Parse(t *testing.T) {
+	entries := parseFixture(t, JVMGC{}, "jvm_gc_sample.log")
+	require.Len(t, entries, 3, "pauses with a wall clock only")
+
+	young := entries[0]
+	assert.Equal(t, domain.LogTypeGC, young.LogType)
+	assert.Equal(t, uint32(4), young.LineNumber)
+	assert.Equal(t, "GC(41)", young.TraceID)
+	assert.Equal(t, "Pause Young (Normal) (G1 Evacuation Pause)", young.Operation)
+	assert.Equal(t, uint32(35), young.DurationMS)
+	logged := time.Date(2025, 10, 10, 13, 55, 30, int(158*time.Millisecond), time.UTC)
+	assert.True(t, young.Timestamp.Equal(logged.Add(-35123*time.Microsecond)), "the pause starts before it is logged")
+	assert.True(t, young.Success)
+	assert.True(t, strings.HasSuffix(young.RawText, "35.123ms"))
+
+	assert.Equal(t, "Pause Remark", entries[1].Operation)
+	assert.Equal(t, uint32(2), entries[1].DurationMS)
+
+	full := entries[2]
+	assert.Equal(t, "Pause Full (G1 Compaction Pause)", full.Operation)
+	assert.Equal(t, uint32(4235), full.DurationMS)
+	assert.Equal(t, "GC(43)", full.TraceID)
+}
+
+func TestJVMGC_ParseZGC(t *testing.T) {
+	var entries []domain.LogEntry
+	err := JVMGC{}.Parse(strings.NewReader(
+		"[2025-10-10T13:55:36.100Z][info][gc,phases] GC(7) Pause Mark Start 0.012ms\n"+
+			"[2025-10-10T13:55:36.200Z][info][gc,phases] GC(7) Concurrent Mark 81.215ms\n",
+	), func(e domain.LogEntry) { entries = append(entries, e) })
+
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Pause Mark Start", entries[0].Operation)
+	assert.Zero(t, entries[0].DurationMS)
+}
+
+func TestJVMGC_Detect(t *testing.T) {
+	assert.True(t, JVMGC{}.Detect(readFixture(t, "jvm_gc_sample.log")))
+	assert.False(t, JVMGC{}.Detect(readFixture(t, "tomcat_access_sample.log")))
+	assert.False(t, JVMGC{}.Detect(readFixture(t, "ar25_sample.log")))
+	assert.False(t, JVMGC{}.Detect([]byte("[INFO][main] started\n")))
+}
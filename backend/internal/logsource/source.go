@@ -0,0 +1,78 @@
+// Package logsource parses the logs of non-AR sources, such as a Tomcat
+// access log or a JVM GC log, into log entries that share the pipeline,
+// the storage and the timelines of AR Server logs. AR Server logs are
+// parsed by the JAR and have no source here.
+package logsource
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// HeaderBytes is the size of the start of a file that Detect is given.
+const HeaderBytes = 4096
+
+// maxLineBytes is the longest line a source reads; longer lines fail the
+// parse.
+const maxLineBytes = 1024 * 1024
+
+// Source is a parser of one kind of non-AR log.
+type Source interface {
+	// Detect reports whether header, the start of a file, holds this kind
+	// of log.
+	Detect(header []byte) bool
+
+	// Parse calls emit with an entry for each event of r in file order.
+	// Entries carry the line number, timestamp, log type and raw text of
+	// the event and whatever else the source maps onto the entry schema;
+	// the caller stamps the tenant, the job and the entry ID. Lines that
+	// are not events are skipped.
+	Parse(r io.Reader, emit func(domain.LogEntry)) error
+}
+
+var sources = map[domain.LogSourceType]Source{
+	domain.LogSourceTomcatAccess: TomcatAccess{},
+	domain.LogSourceJVMGC:        JVMGC{},
+}
+
+// Lookup returns the source of a log source type. AR Server logs have
+// none.
+func Lookup(t domain.LogSourceType) (Source, bool) {
+	s, ok := sources[t]
+	return s, ok
+}
+
+// detectLines reports whether most of the complete, non-blank lines of
+// header match. The last line is left out when the header cuts it short.
+func detectLines(header []byte, match func(line string) bool) bool {
+	if i := bytes.LastIndexByte(header, '\n'); i >= 0 && i < len(header)-1 && len(header) >= HeaderBytes {
+		header = header[:i]
+	}
+	lines, matched := 0, 0
+	for _, line := range bytes.Split(header, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		lines++
+		if match(string(line)) {
+			matched++
+		}
+	}
+	return matched > 0 && matched*2 >= lines
+}
+
+// scanLines calls fn with each line of r and its 1-based line number.
+func scanLines(r io.Reader, fn func(line string, lineNumber uint32)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	var n uint32
+	for scanner.Scan() {
+		n++
+		fn(string(bytes.TrimRight(scanner.Bytes(), "\r")), n)
+	}
+	return scanner.Err()
+}
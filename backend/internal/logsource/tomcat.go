@@ -0,0 +1,80 @@
+package logsource
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// tomcatLineRegex matches a line of the common or combined access log
+// format, optionally followed by the processing time in milliseconds
+// (Tomcat's %D up to 9.x, or %{ms}T):
+//
+//	10.0.0.5 - Demo [10/Oct/2025:13:55:36 +0000] "GET /arsys/forms/x?a=1 HTTP/1.1" 200 2326 "-" "Mozilla/5.0" 412
+var tomcatLineRegex = regexp.MustCompile(`^(\S+) \S+ (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (?:\d+|-)(?: "[^"]*" "([^"]*)")?(?: (\d+))?\s*$`)
+
+const tomcatTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// TomcatAccess parses Tomcat access logs. Each request is an HTTP entry:
+// the method is the operation, the path without its query string the form
+// and the user agent the client. Requests answered with a 5xx status fail.
+type TomcatAccess struct{}
+
+func (TomcatAccess) Detect(header []byte) bool {
+	return detectLines(header, tomcatLineRegex.MatchString)
+}
+
+func (TomcatAccess) Parse(r io.Reader, emit func(domain.LogEntry)) error {
+	return scanLines(r, func(line string, lineNumber uint32) {
+		if e, ok := parseTomcatLine(line); ok {
+			e.LineNumber = lineNumber
+			emit(e)
+		}
+	})
+}
+
+func parseTomcatLine(line string) (domain.LogEntry, bool) {
+	m := tomcatLineRegex.FindStringSubmatch(line)
+	if m == nil {
+		return domain.LogEntry{}, false
+	}
+	ts, err := time.Parse(tomcatTimeLayout, m[3])
+	if err != nil {
+		return domain.LogEntry{}, false
+	}
+	status, _ := strconv.Atoi(m[5])
+
+	e := domain.LogEntry{
+		Timestamp: domain.NewTimestamp(ts),
+		LogType:   domain.LogTypeHTTP,
+		ClientIP:  m[1],
+		Success:   status < 500,
+		RawText:   line,
+	}
+	if m[2] != "-" {
+		e.User = m[2]
+	}
+	if m[6] != "-" {
+		e.Client = m[6]
+	}
+	// The request line is "METHOD URI PROTOCOL", or "-" when the client
+	// sent none.
+	if fields := strings.Fields(m[4]); len(fields) >= 2 {
+		e.Operation = fields[0]
+		e.Form, _, _ = strings.Cut(fields[1], "?")
+	}
+	if m[7] != "" {
+		if ms, err := strconv.ParseUint(m[7], 10, 32); err == nil {
+			e.DurationMS = uint32(ms)
+		}
+	}
+	if !e.Success {
+		e.ErrorMessage = fmt.Sprintf("HTTP %d", status)
+	}
+	return e, true
+}
@@ -0,0 +1,90 @@
+package logsource
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("..", "..", "testdata", name))
+	require.NoError(t, err)
+	return content
+}
+
+func parseFixture(t *testing.T, s Source, name string) []domain.LogEntry {
+	t.Helper()
+	var entries []domain.LogEntry
+	require.NoError(t, s.Parse(strings.NewReader(string(readFixture(t, name))), func(e domain.LogEntry) {
+		entries = append(entries, e)
+	}))
+	return entries
+}
+
+func TestTomcatAccess_Parse(t *testing.T) {
+	entries := parseFixture(t, TomcatAccess{}, "tomcat_access_sample.log")
+	require.Len(t, entries, 6)
+
+	first := entries[0]
+	assert.Equal(t, domain.LogTypeHTTP, first.LogType)
+	assert.Equal(t, uint32(1), first.LineNumber)
+	assert.True(t, first.Timestamp.Equal(time.Date(2025, 10, 10, 13, 55, 36, 0, time.UTC)))
+	assert.Equal(t, "10.20.1.15", first.ClientIP)
+	assert.Empty(t, first.User)
+	assert.Equal(t, "GET", first.Operation)
+	assert.Equal(t, "/arsys/forms/onbmc-s/HPD%3AHelp+Desk/Default+User+View/", first.Form, "the query string is dropped")
+	assert.Equal(t, "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", first.Client)
+	assert.Equal(t, uint32(412), first.DurationMS)
+	assert.True(t, first.Success)
+	assert.Contains(t, first.RawText, "cacheid=5ac8")
+
+	assert.Equal(t, "Demo", entries[1].User)
+	assert.Equal(t, uint32(8734), entries[1].DurationMS)
+
+	failed := entries[2]
+	assert.False(t, failed.Success)
+	assert.Equal(t, "HTTP 503", failed.ErrorMessage)
+	assert.True(t, entries[3].Success, "client errors do not fail the request")
+	assert.Empty(t, entries[3].ErrorMessage)
+
+	common := entries[4]
+	assert.Equal(t, uint32(6), common.LineNumber, "the line that is not a request still counts")
+	assert.Empty(t, common.Client)
+	assert.Zero(t, common.DurationMS)
+
+	assert.Empty(t, entries[5].Operation)
+	for _, e := range entries {
+		assert.NotEmpty(t, e.RawText)
+	}
+}
+
+func TestTomcatAccess_Detect(t *testing.T) {
+	assert.True(t, TomcatAccess{}.Detect(readFixture(t, "tomcat_access_sample.log")))
+	assert.False(t, TomcatAccess{}.Detect(readFixture(t, "jvm_gc_sample.log")))
+	assert.False(t, TomcatAccess{}.Detect(readFixture(t, "ar25_sample.log")))
+	assert.False(t, TomcatAccess{}.Detect(nil))
+
+	// A header cut within a line is judged by its complete lines.
+	line := `10.0.0.1 - - [10/Oct/2025:13:55:36 +0000] "GET / HTTP/1.1" 200 12` + "\n"
+	header := []byte(strings.Repeat(line, HeaderBytes/len(line)+1)[:HeaderBytes])
+	assert.True(t, TomcatAccess{}.Detect(header))
+}
+
+func TestLookup(t *testing.T) {
+	s, ok := Lookup(domain.LogSourceTomcatAccess)
+	require.True(t, ok)
+	assert.IsType(t, TomcatAccess{}, s)
+	s, ok = Lookup(domain.LogSourceJVMGC)
+	require.True(t, ok)
+	assert.IsType(t, JVMGC{}, s)
+	_, ok = Lookup(domain.LogSourceARServer)
+	assert.False(t, ok)
+}
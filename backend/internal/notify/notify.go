@@ -0,0 +1,16 @@
+// Package notify delivers tenant notifications such as the daily digest.
+package notify
+
+import "context"
+
+// Message is a rendered notification addressed to one or more recipients.
+type Message struct {
+	To      []string
+	Subject string
+	HTML    string
+}
+
+// Notifier delivers a message through one channel.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends messages as HTML email through an SMTP relay. Plain
+// authentication is used when a username is configured; net/smtp upgrades
+// the connection with STARTTLS when the server offers it.
+type SMTPNotifier struct {
+	addr     string
+	from     string
+	auth     smtp.Auth
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	now      func() time.Time
+}
+
+// NewSMTPNotifier creates an SMTPNotifier for the relay at host:port.
+func NewSMTPNotifier(host string, port int, username, password, from string) *SMTPNotifier {
+	n := &SMTPNotifier{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		from:     from,
+		sendMail: smtp.SendMail,
+		now:      time.Now,
+	}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// Send delivers msg to all of its recipients in a single transaction.
+// net/smtp has no context support, so ctx is only checked before dialling.
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("notify: smtp: message has no recipients")
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("notify: smtp: %w", err)
+	}
+	if err := n.sendMail(n.addr, n.auth, n.from, msg.To, n.buildMessage(msg)); err != nil {
+		return fmt.Errorf("notify: smtp send: %w", err)
+	}
+	return nil
+}
+
+// buildMessage renders msg as an RFC 5322 message with an HTML body.
+func (n *SMTPNotifier) buildMessage(msg Message) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", n.from)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", n.now().UTC().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/html; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.HTML, "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPNotifier_Send(t *testing.T) {
+	n := NewSMTPNotifier("mail.example.com", 587, "bot", "secret", "RemedyIQ <digest@example.com>")
+	n.now = func() time.Time { return time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC) }
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		assert.NotNil(t, a)
+		return nil
+	}
+
+	err := n.Send(context.Background(), Message{
+		To:      []string{"ops@example.com", "dba@example.com"},
+		Subject: "Daily digest – 2 analyses",
+		HTML:    "<p>hello</p>\n<p>world</p>",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "mail.example.com:587", gotAddr)
+	assert.Equal(t, "RemedyIQ <digest@example.com>", gotFrom)
+	assert.Equal(t, []string{"ops@example.com", "dba@example.com"}, gotTo)
+
+	msg := string(gotMsg)
+	assert.Contains(t, msg, "To: ops@example.com, dba@example.com\r\n")
+	assert.Contains(t, msg, "Subject: =?utf-8?q?")
+	assert.Contains(t, msg, "Date: Mon, 02 Mar 2026 07:00:00 +0000\r\n")
+	assert.Contains(t, msg, "Content-Type: text/html; charset=\"utf-8\"\r\n")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\n<p>hello</p>\r\n<p>world</p>"))
+}
+
+func TestSMTPNotifier_NoAuthWithoutUsername(t *testing.T) {
+	n := NewSMTPNotifier("localhost", 25, "", "", "digest@example.com")
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Nil(t, a)
+		return nil
+	}
+	require.NoError(t, n.Send(context.Background(), Message{To: []string{"ops@example.com"}}))
+}
+
+func TestSMTPNotifier_Errors(t *testing.T) {
+	n := NewSMTPNotifier("localhost", 25, "", "", "digest@example.com")
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		return errors.New("connection refused")
+	}
+
+	err := n.Send(context.Background(), Message{})
+	assert.ErrorContains(t, err, "no recipients")
+
+	err = n.Send(context.Background(), Message{To: []string{"ops@example.com"}})
+	assert.ErrorContains(t, err, "connection refused")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = n.Send(ctx, Message{To: []string{"ops@example.com"}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,123 @@
+// Package preferences holds the registered namespaces of user preferences
+// and how their layers are merged.
+//
+// A namespace's effective document is built from three layers, each taking
+// precedence over the one before it:
+//
+//  1. the built-in defaults of the namespace,
+//  2. the tenant defaults, set by tenant administrators,
+//  3. the user's own preferences.
+//
+// Objects are merged key by key, recursively. Any other value, arrays
+// included, replaces the value of the lower layers as a whole. A null
+// leaves the lower layers' value in place, so a user can drop an override
+// to fall back to the tenant default.
+package preferences
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// MaxDocumentBytes caps the stored JSON document of one namespace.
+const MaxDocumentBytes = 32 * 1024
+
+// Namespace is a registered preferences namespace.
+type Namespace struct {
+	Name string
+	// SchemaVersion is the current version of the namespace's document
+	// layout. Documents written by older clients keep their version so the
+	// frontend can migrate them on read.
+	SchemaVersion int
+	// Defaults are the built-in defaults, the lowest layer of the merge.
+	Defaults map[string]any
+}
+
+var namespaces = map[string]Namespace{
+	"log_viewer": {
+		Name:          "log_viewer",
+		SchemaVersion: 1,
+		Defaults:      map[string]any{"page_size": 100},
+	},
+	"dashboard": {
+		Name:          "dashboard",
+		SchemaVersion: 1,
+		Defaults:      map[string]any{"top_n": 25},
+	},
+	"search": {
+		Name:          "search",
+		SchemaVersion: 1,
+		Defaults:      map[string]any{"page_size": 50},
+	},
+}
+
+// Lookup returns the registered namespace name.
+func Lookup(name string) (Namespace, bool) {
+	ns, ok := namespaces[name]
+	return ns, ok
+}
+
+// Names returns the registered namespaces in name order.
+func Names() []string {
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Decode parses a stored or submitted document. Documents must be JSON
+// objects; an empty document is the empty object.
+func Decode(raw json.RawMessage) (map[string]any, error) {
+	if len(raw) == 0 {
+		return map[string]any{}, nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("document must be a JSON object: %w", err)
+	}
+	if doc == nil {
+		return map[string]any{}, nil
+	}
+	return doc, nil
+}
+
+// Merge returns the layers merged in order, each one overriding those
+// before it. The layers are not modified.
+func Merge(layers ...map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, layer := range layers {
+		out = mergeInto(out, layer)
+	}
+	return out
+}
+
+func mergeInto(dst, src map[string]any) map[string]any {
+	for key, v := range src {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case map[string]any:
+			base, _ := dst[key].(map[string]any)
+			dst[key] = mergeInto(copyObject(base), v)
+		default:
+			dst[key] = v
+		}
+	}
+	return dst
+}
+
+// copyObject copies the objects of m so that merging into the copy leaves
+// m untouched. Other values are shared; they are never modified.
+func copyObject(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for key, v := range m {
+		if obj, ok := v.(map[string]any); ok {
+			v = copyObject(obj)
+		}
+		out[key] = v
+	}
+	return out
+}
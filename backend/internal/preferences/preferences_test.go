@@ -0,0 +1,77 @@
+package preferences
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge_Precedence(t *testing.T) {
+	builtin := map[string]any{"page_size": 100, "columns": []any{"time", "type"}, "wrap": false}
+	tenant := map[string]any{
+		"page_size": 200,
+		"layout":    map[string]any{"density": "compact", "panels": map[string]any{"left": 30, "right": 70}},
+	}
+	user := map[string]any{
+		"columns": []any{"time"},
+		"layout":  map[string]any{"panels": map[string]any{"left": 40}},
+		"wrap":    nil, // falls back to the lower layers
+	}
+
+	got := Merge(builtin, tenant, user)
+
+	assert.Equal(t, map[string]any{
+		"page_size": 200,
+		"columns":   []any{"time"},
+		"wrap":      false,
+		"layout": map[string]any{
+			"density": "compact",
+			"panels":  map[string]any{"left": 40, "right": 70},
+		},
+	}, got)
+	assert.Equal(t, 30, tenant["layout"].(map[string]any)["panels"].(map[string]any)["left"], "layers are not modified")
+}
+
+func TestMerge_ValueReplacesObject(t *testing.T) {
+	got := Merge(map[string]any{"layout": map[string]any{"density": "compact"}}, map[string]any{"layout": "classic"})
+	assert.Equal(t, map[string]any{"layout": "classic"}, got)
+
+	got = Merge(map[string]any{"layout": "classic"}, map[string]any{"layout": map[string]any{"density": "compact"}})
+	assert.Equal(t, map[string]any{"layout": map[string]any{"density": "compact"}}, got)
+}
+
+func TestMerge_NoLayers(t *testing.T) {
+	assert.Equal(t, map[string]any{}, Merge())
+	assert.Equal(t, map[string]any{}, Merge(nil, nil))
+}
+
+func TestDecode(t *testing.T) {
+	doc, err := Decode(json.RawMessage(`{"page_size": 50}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"page_size": float64(50)}, doc)
+
+	for _, raw := range []string{"", "null"} {
+		doc, err = Decode(json.RawMessage(raw))
+		require.NoError(t, err, raw)
+		assert.Equal(t, map[string]any{}, doc, raw)
+	}
+
+	for _, raw := range []string{"[1, 2]", `"compact"`, "{"} {
+		_, err = Decode(json.RawMessage(raw))
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, []string{"dashboard", "log_viewer", "search"}, Names())
+	for _, name := range Names() {
+		ns, ok := Lookup(name)
+		require.True(t, ok, name)
+		assert.Equal(t, name, ns.Name)
+		assert.Positive(t, ns.SchemaVersion)
+	}
+	_, ok := Lookup("defaults")
+	assert.False(t, ok)
+}
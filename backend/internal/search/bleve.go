@@ -91,6 +91,8 @@ func buildIndexMapping() mapping.IndexMapping {
 	logMapping.AddFieldMappingsAt("user", keywordField)
 	logMapping.AddFieldMappingsAt("form", textField)
 	logMapping.AddFieldMappingsAt("api_code", keywordField)
+	logMapping.AddFieldMappingsAt("client", keywordField)
+	logMapping.AddFieldMappingsAt("client_ip", keywordField)
 	logMapping.AddFieldMappingsAt("sql_table", keywordField)
 	logMapping.AddFieldMappingsAt("filter_name", textField)
 	logMapping.AddFieldMappingsAt("esc_name", textField)
@@ -144,6 +146,8 @@ func entryToDoc(e domain.LogEntry) map[string]interface{} {
 		"user":          e.User,
 		"form":          e.Form,
 		"api_code":      e.APICode,
+		"client":        e.Client,
+		"client_ip":     e.ClientIP,
 		"sql_table":     e.SQLTable,
 		"sql_statement": e.SQLStatement,
 		"filter_name":   e.FilterName,
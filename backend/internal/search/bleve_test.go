@@ -33,7 +33,7 @@ func TestBleveManager_CreateAndSearch(t *testing.T) {
 			APICode:    "GET_ENTRY",
 			DurationMS: 5000,
 			Success:    true,
-			Timestamp:  time.Now(),
+			Timestamp:  domain.NewTimestamp(time.Now()),
 			LineNumber: 100,
 		},
 		{
@@ -44,7 +44,7 @@ func TestBleveManager_CreateAndSearch(t *testing.T) {
 			SQLTable:   "T1234",
 			DurationMS: 2000,
 			Success:    true,
-			Timestamp:  time.Now(),
+			Timestamp:  domain.NewTimestamp(time.Now()),
 			LineNumber: 200,
 		},
 	}
@@ -74,13 +74,13 @@ func TestBleveManager_TenantIsolation(t *testing.T) {
 
 	// Index entries for two tenants
 	err = bm.IndexEntries(context.Background(), "tenant-A", []domain.LogEntry{
-		{EntryID: "a-1", LogType: domain.LogTypeAPI, Timestamp: time.Now()},
+		{EntryID: "a-1", LogType: domain.LogTypeAPI, Timestamp: domain.NewTimestamp(time.Now())},
 	})
 	require.NoError(t, err)
 
 	err = bm.IndexEntries(context.Background(), "tenant-B", []domain.LogEntry{
-		{EntryID: "b-1", LogType: domain.LogTypeSQL, Timestamp: time.Now()},
-		{EntryID: "b-2", LogType: domain.LogTypeSQL, Timestamp: time.Now()},
+		{EntryID: "b-1", LogType: domain.LogTypeSQL, Timestamp: domain.NewTimestamp(time.Now())},
+		{EntryID: "b-2", LogType: domain.LogTypeSQL, Timestamp: domain.NewTimestamp(time.Now())},
 	})
 	require.NoError(t, err)
 
@@ -127,7 +127,7 @@ func TestBleveManager_ContextCancellation(t *testing.T) {
 	cancel() // Cancel immediately
 
 	entries := []domain.LogEntry{
-		{EntryID: "e-1", LogType: domain.LogTypeAPI, Timestamp: time.Now()},
+		{EntryID: "e-1", LogType: domain.LogTypeAPI, Timestamp: domain.NewTimestamp(time.Now())},
 	}
 
 	err = bm.IndexEntries(ctx, "tenant-cancel", entries)
@@ -151,7 +151,7 @@ func TestBleveManager_SearchWithFields(t *testing.T) {
 			User:       "Demo",
 			Form:       "HPD:Help Desk",
 			DurationMS: 5000,
-			Timestamp:  time.Now(),
+			Timestamp:  domain.NewTimestamp(time.Now()),
 		},
 		{
 			EntryID:    "e-2",
@@ -160,7 +160,7 @@ func TestBleveManager_SearchWithFields(t *testing.T) {
 			User:       "Admin",
 			Form:       "HPD:Incident",
 			DurationMS: 100,
-			Timestamp:  time.Now(),
+			Timestamp:  domain.NewTimestamp(time.Now()),
 		},
 	}
 
@@ -189,9 +189,9 @@ func TestBleveManager_NumericRangeSearch(t *testing.T) {
 	defer bm.Close()
 
 	entries := []domain.LogEntry{
-		{EntryID: "fast", LogType: domain.LogTypeAPI, DurationMS: 50, Timestamp: time.Now()},
-		{EntryID: "medium", LogType: domain.LogTypeAPI, DurationMS: 500, Timestamp: time.Now()},
-		{EntryID: "slow", LogType: domain.LogTypeAPI, DurationMS: 5000, Timestamp: time.Now()},
+		{EntryID: "fast", LogType: domain.LogTypeAPI, DurationMS: 50, Timestamp: domain.NewTimestamp(time.Now())},
+		{EntryID: "medium", LogType: domain.LogTypeAPI, DurationMS: 500, Timestamp: domain.NewTimestamp(time.Now())},
+		{EntryID: "slow", LogType: domain.LogTypeAPI, DurationMS: 5000, Timestamp: domain.NewTimestamp(time.Now())},
 	}
 
 	err = bm.IndexEntries(context.Background(), "tenant-numeric", entries)
@@ -221,7 +221,7 @@ func TestBleveManager_DeleteIndex(t *testing.T) {
 
 	// Create and populate an index
 	err = bm.IndexEntries(context.Background(), "tenant-del", []domain.LogEntry{
-		{EntryID: "d-1", LogType: domain.LogTypeAPI, Timestamp: time.Now()},
+		{EntryID: "d-1", LogType: domain.LogTypeAPI, Timestamp: domain.NewTimestamp(time.Now())},
 	})
 	require.NoError(t, err)
 
@@ -247,7 +247,7 @@ func TestBleveManager_IndexAlias(t *testing.T) {
 	defer bm.Close()
 
 	entries := []domain.LogEntry{
-		{EntryID: "idx-1", LogType: domain.LogTypeAPI, User: "Test", Timestamp: time.Now()},
+		{EntryID: "idx-1", LogType: domain.LogTypeAPI, User: "Test", Timestamp: domain.NewTimestamp(time.Now())},
 	}
 
 	// Use the Index alias method (wraps IndexEntries).
@@ -273,7 +273,7 @@ func TestBleveManager_DeleteAlias(t *testing.T) {
 
 	// Create an index with data.
 	err = bm.IndexEntries(context.Background(), "tenant-del2", []domain.LogEntry{
-		{EntryID: "d-1", LogType: domain.LogTypeAPI, Timestamp: time.Now()},
+		{EntryID: "d-1", LogType: domain.LogTypeAPI, Timestamp: domain.NewTimestamp(time.Now())},
 	})
 	require.NoError(t, err)
 
@@ -0,0 +1,546 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits of the computed columns of one search.
+const (
+	MaxComputedColumns = 5
+	MaxExprNodes       = 32
+	maxExprLength      = 256
+	maxComputedName    = 32
+)
+
+// ComputedColumn is a named expression evaluated for each search hit, e.g.
+// {"name": "total_ms", "expr": "duration_ms + queue_time_ms"}.
+type ComputedColumn struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// ComputedType is the type of the value of a computed column.
+type ComputedType string
+
+const (
+	ComputedNumber ComputedType = "number"
+	ComputedString ComputedType = "string"
+	ComputedBool   ComputedType = "bool"
+)
+
+// CompiledColumn is a computed column compiled to a ClickHouse SELECT
+// expression. SQL uses positional ? placeholders for Params, like
+// ToClickHouseWhere.
+type CompiledColumn struct {
+	Name   string
+	Type   ComputedType
+	SQL    string
+	Params []interface{}
+}
+
+// computedField is a column an expression may read and its SQL.
+type computedField struct {
+	sql string
+	typ ComputedType
+}
+
+// computedFields are the columns expressions may read, by ClickHouse
+// column name. The tenant and job columns, raw text and SQL statements are
+// deliberately absent. Numbers are read as Float64 so arithmetic neither
+// wraps nor truncates.
+var computedFields = map[string]computedField{
+	"duration_ms":   {"toFloat64(duration_ms)", ComputedNumber},
+	"queue_time_ms": {"toFloat64(queue_time_ms)", ComputedNumber},
+	"delay_ms":      {"toFloat64(delay_ms)", ComputedNumber},
+	"line_number":   {"toFloat64(line_number)", ComputedNumber},
+	"file_number":   {"toFloat64(file_number)", ComputedNumber},
+	"log_type":      {"toString(log_type)", ComputedString},
+	"user":          {"user", ComputedString},
+	"form":          {"form", ComputedString},
+	"queue":         {"queue", ComputedString},
+	"thread_id":     {"thread_id", ComputedString},
+	"trace_id":      {"trace_id", ComputedString},
+	"rpc_id":        {"rpc_id", ComputedString},
+	"api_code":      {"api_code", ComputedString},
+	"client":        {"client", ComputedString},
+	"client_ip":     {"client_ip", ComputedString},
+	"sql_table":     {"sql_table", ComputedString},
+	"filter_name":   {"filter_name", ComputedString},
+	"operation":     {"operation", ComputedString},
+	"request_id":    {"request_id", ComputedString},
+	"esc_name":      {"esc_name", ComputedString},
+	"esc_pool":      {"esc_pool", ComputedString},
+	"error_message": {"error_message", ComputedString},
+	"success":       {"success", ComputedBool},
+}
+
+// CompileComputed validates computed columns and compiles them to ClickHouse
+// expressions. Anything outside the grammar is rejected:
+//
+//	expr    = sum [("=" | "!=" | "<" | "<=" | ">" | ">=") sum]
+//	sum     = product {("+" | "-") product}
+//	product = unary {("*" | "/") unary}
+//	unary   = ["-"] primary
+//	primary = number | 'string' | field | func "(" [expr {"," expr}] ")" | "(" expr ")"
+//	func    = substr | upper | lower | concat | length | position
+//
+// Fields are those of KQL, limited to computedFields.
+func CompileComputed(cols []ComputedColumn) ([]CompiledColumn, error) {
+	if len(cols) > MaxComputedColumns {
+		return nil, fmt.Errorf("at most %d computed columns are allowed", MaxComputedColumns)
+	}
+	out := make([]CompiledColumn, 0, len(cols))
+	seen := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		if err := validComputedName(col.Name); err != nil {
+			return nil, err
+		}
+		if seen[col.Name] {
+			return nil, fmt.Errorf("computed column %q is defined twice", col.Name)
+		}
+		seen[col.Name] = true
+
+		node, err := parseExpr(col.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("computed column %q: %w", col.Name, err)
+		}
+		sql, params := node.toSQL()
+		switch node.typ {
+		case ComputedBool:
+			sql = "toUInt8(" + sql + ")"
+		case ComputedString:
+			sql = "toString(" + sql + ")"
+		}
+		out = append(out, CompiledColumn{Name: col.Name, Type: node.typ, SQL: sql, Params: params})
+	}
+	return out, nil
+}
+
+// validComputedName checks that a computed column name is a short
+// lower-case identifier that does not shadow a field.
+func validComputedName(name string) error {
+	if name == "" || len(name) > maxComputedName {
+		return fmt.Errorf("computed column name must be 1 to %d characters", maxComputedName)
+	}
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '_' || i > 0 && r >= '0' && r <= '9') {
+			return fmt.Errorf("invalid computed column name %q: use lower-case letters, digits and underscores", name)
+		}
+	}
+	if _, ok := KnownFields[name]; ok {
+		return fmt.Errorf("computed column name %q is a field name", name)
+	}
+	if _, ok := computedFields[name]; ok {
+		return fmt.Errorf("computed column name %q is a field name", name)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Expression parser
+// --------------------------------------------------------------------------
+
+type exprKind int
+
+const (
+	exprNumber exprKind = iota
+	exprString
+	exprField
+	exprBinary
+	exprNeg
+	exprCall
+)
+
+// exprNode is a type-checked node of a computed column expression.
+type exprNode struct {
+	kind exprKind
+	typ  ComputedType
+	op   string // operator or function name
+	num  float64
+	str  string // string literal or field SQL
+	args []*exprNode
+}
+
+type exprFunc struct {
+	minArgs, maxArgs int
+	argTypes         func(i int, t ComputedType) bool
+	typ              ComputedType
+}
+
+func isString(_ int, t ComputedType) bool { return t == ComputedString }
+
+// exprFuncs is the whitelist of functions.
+var exprFuncs = map[string]exprFunc{
+	"substr": {2, 3, func(i int, t ComputedType) bool {
+		return i == 0 && t == ComputedString || i > 0 && t == ComputedNumber
+	}, ComputedString},
+	"upper":    {1, 1, isString, ComputedString},
+	"lower":    {1, 1, isString, ComputedString},
+	"length":   {1, 1, isString, ComputedNumber},
+	"position": {2, 2, isString, ComputedNumber},
+	"concat": {2, 8, func(_ int, t ComputedType) bool {
+		return t != ComputedBool
+	}, ComputedString},
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	nodes  int
+}
+
+type exprToken struct {
+	kind byte // 'n' number, 's' string, 'i' identifier, 'o' operator, 0 end
+	val  string
+	at   int
+}
+
+func parseExpr(input string) (*exprNode, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+	if len(input) > maxExprLength {
+		return nil, fmt.Errorf("expression longer than %d characters", maxExprLength)
+	}
+	tokens, err := tokenizeExpr(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != 0 {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.at)
+	}
+	return node, nil
+}
+
+func tokenizeExpr(input string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		ch := runes[i]
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch >= '0' && ch <= '9' || ch == '.':
+			start := i
+			for i < len(runes) && (runes[i] >= '0' && runes[i] <= '9' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: 'n', val: string(runes[start:i]), at: start})
+		case ch == '\'' || ch == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(runes) && runes[i] != ch {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start)
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: 's', val: sb.String(), at: start})
+		case unicode.IsLetter(ch) || ch == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: 'i', val: string(runes[start:i]), at: start})
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch {
+			case two == "!=" || two == "<=" || two == ">=" || two == "==" || two == "<>":
+				i += 2
+			case strings.ContainsRune("+-*/()=<>,", ch):
+				i++
+			default:
+				return nil, fmt.Errorf("unexpected character %q at position %d", string(ch), start)
+			}
+			tokens = append(tokens, exprToken{kind: 'o', val: string(runes[start:i]), at: start})
+		}
+	}
+	return append(tokens, exprToken{at: len(runes)}), nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) advance() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != 'o' {
+		return false
+	}
+	for _, op := range ops {
+		if t.val == op {
+			return true
+		}
+	}
+	return false
+}
+
+// node counts a new node against MaxExprNodes.
+func (p *exprParser) node(n *exprNode) (*exprNode, error) {
+	p.nodes++
+	if p.nodes > MaxExprNodes {
+		return nil, fmt.Errorf("expression has more than %d terms", MaxExprNodes)
+	}
+	return n, nil
+}
+
+func (p *exprParser) parseCompare() (*exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("=", "==", "!=", "<>", "<", "<=", ">", ">=") {
+		return left, nil
+	}
+	opTok := p.advance()
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op := opTok.val
+	switch op {
+	case "==":
+		op = "="
+	case "<>":
+		op = "!="
+	}
+	if left.typ != right.typ {
+		return nil, fmt.Errorf("cannot compare %s with %s at position %d", left.typ, right.typ, opTok.at)
+	}
+	if left.typ == ComputedBool && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("operator %s does not apply to bool at position %d", opTok.val, opTok.at)
+	}
+	if p.isOp("=", "==", "!=", "<>", "<", "<=", ">", ">=") {
+		return nil, fmt.Errorf("comparisons cannot be chained at position %d", p.peek().at)
+	}
+	return p.node(&exprNode{kind: exprBinary, typ: ComputedBool, op: op, args: []*exprNode{left, right}})
+}
+
+func (p *exprParser) parseSum() (*exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		opTok := p.advance()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.arithmetic(opTok, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseProduct() (*exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/") {
+		opTok := p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.arithmetic(opTok, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) arithmetic(opTok exprToken, left, right *exprNode) (*exprNode, error) {
+	if left.typ != ComputedNumber || right.typ != ComputedNumber {
+		return nil, fmt.Errorf("operator %s needs numbers at position %d; use concat to join strings", opTok.val, opTok.at)
+	}
+	return p.node(&exprNode{kind: exprBinary, typ: ComputedNumber, op: opTok.val, args: []*exprNode{left, right}})
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	if !p.isOp("-") {
+		return p.parsePrimary()
+	}
+	opTok := p.advance()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if operand.typ != ComputedNumber {
+		return nil, fmt.Errorf("operator - needs a number at position %d", opTok.at)
+	}
+	return p.node(&exprNode{kind: exprNeg, typ: ComputedNumber, args: []*exprNode{operand}})
+}
+
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	t := p.advance()
+	switch t.kind {
+	case 'n':
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.val, t.at)
+		}
+		return p.node(&exprNode{kind: exprNumber, typ: ComputedNumber, num: v})
+	case 's':
+		return p.node(&exprNode{kind: exprString, typ: ComputedString, str: t.val})
+	case 'i':
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		name := strings.ToLower(t.val)
+		col := name
+		if known, ok := KnownFields[name]; ok {
+			col = known
+		}
+		field, ok := computedFields[col]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q at position %d", t.val, t.at)
+		}
+		return p.node(&exprNode{kind: exprField, typ: field.typ, str: field.sql})
+	case 'o':
+		if t.val == "(" {
+			inner, err := p.parseCompare()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, fmt.Errorf("missing closing parenthesis at position %d", p.peek().at)
+			}
+			p.advance()
+			return inner, nil
+		}
+	case 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.at)
+}
+
+func (p *exprParser) parseCall(nameTok exprToken) (*exprNode, error) {
+	name := strings.ToLower(nameTok.val)
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", nameTok.val, nameTok.at)
+	}
+	p.advance() // (
+	var args []*exprNode
+	if !p.isOp(")") {
+		for {
+			arg, err := p.parseCompare()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.isOp(",") {
+				break
+			}
+			p.advance()
+		}
+	}
+	if !p.isOp(")") {
+		return nil, fmt.Errorf("missing closing parenthesis of %s at position %d", name, p.peek().at)
+	}
+	p.advance()
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("%s takes %s arguments, got %d", name, argCount(fn), len(args))
+	}
+	for i, arg := range args {
+		if !fn.argTypes(i, arg.typ) {
+			return nil, fmt.Errorf("argument %d of %s cannot be a %s", i+1, name, arg.typ)
+		}
+	}
+	return p.node(&exprNode{kind: exprCall, typ: fn.typ, op: name, args: args})
+}
+
+func argCount(fn exprFunc) string {
+	if fn.minArgs == fn.maxArgs {
+		return strconv.Itoa(fn.minArgs)
+	}
+	return fmt.Sprintf("%d to %d", fn.minArgs, fn.maxArgs)
+}
+
+// --------------------------------------------------------------------------
+// ClickHouse SQL generation
+// --------------------------------------------------------------------------
+
+// toSQL renders the node as a ClickHouse expression. Literals are always
+// parameters. Division by zero yields 0 rather than inf.
+func (n *exprNode) toSQL() (string, []interface{}) {
+	switch n.kind {
+	case exprNumber:
+		return "toFloat64(?)", []interface{}{n.num}
+	case exprString:
+		return "?", []interface{}{n.str}
+	case exprField:
+		return n.str, nil
+	case exprNeg:
+		sql, params := n.args[0].toSQL()
+		return "(-" + sql + ")", params
+	case exprBinary:
+		left, leftParams := n.args[0].toSQL()
+		right, rightParams := n.args[1].toSQL()
+		if n.op == "/" {
+			var params []interface{}
+			params = append(params, rightParams...)
+			params = append(params, leftParams...)
+			params = append(params, rightParams...)
+			return fmt.Sprintf("if(%s = 0, 0, %s / %s)", right, left, right), params
+		}
+		return fmt.Sprintf("(%s %s %s)", left, n.op, right), append(leftParams, rightParams...)
+	case exprCall:
+		args := make([]string, len(n.args))
+		var params []interface{}
+		for i, arg := range n.args {
+			sql, argParams := arg.toSQL()
+			switch {
+			case n.op == "substr" && i > 0:
+				sql = "toInt64(" + sql + ")"
+			case n.op == "concat" && arg.typ == ComputedNumber:
+				sql = "toString(" + sql + ")"
+			}
+			args[i] = sql
+			params = append(params, argParams...)
+		}
+		joined := strings.Join(args, ", ")
+		switch n.op {
+		case "substr":
+			return "substringUTF8(" + joined + ")", params
+		case "upper":
+			return "upperUTF8(" + joined + ")", params
+		case "lower":
+			return "lowerUTF8(" + joined + ")", params
+		case "length":
+			return "toFloat64(lengthUTF8(" + joined + "))", params
+		case "position":
+			return "toFloat64(positionUTF8(" + joined + "))", params
+		case "concat":
+			return "concat(" + joined + ")", params
+		}
+	}
+	panic(fmt.Sprintf("search: unhandled expression node %d", n.kind))
+}
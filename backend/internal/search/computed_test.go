@@ -0,0 +1,197 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compileOne(t *testing.T, expr string) CompiledColumn {
+	t.Helper()
+	cols, err := CompileComputed([]ComputedColumn{{Name: "c", Expr: expr}})
+	require.NoError(t, err)
+	require.Len(t, cols, 1)
+	return cols[0]
+}
+
+// ---------------------------------------------------------------------------
+// CompileComputed - SQL generation
+// ---------------------------------------------------------------------------
+
+func TestCompileComputed_SQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		typ    ComputedType
+		sql    string
+		params []interface{}
+	}{
+		{"sum of fields", "duration_ms + queue_time_ms", ComputedNumber,
+			"(toFloat64(duration_ms) + toFloat64(queue_time_ms))", nil},
+		{"comparison", "duration_ms > 2000", ComputedBool,
+			"toUInt8((toFloat64(duration_ms) > toFloat64(?)))", []interface{}{2000.0}},
+		{"precedence", "1 + 2 * duration_ms", ComputedNumber,
+			"(toFloat64(?) + (toFloat64(?) * toFloat64(duration_ms)))", []interface{}{1.0, 2.0}},
+		{"parentheses", "(1 + 2) * duration_ms", ComputedNumber,
+			"((toFloat64(?) + toFloat64(?)) * toFloat64(duration_ms))", []interface{}{1.0, 2.0}},
+		{"negation", "-delay_ms", ComputedNumber, "(-toFloat64(delay_ms))", nil},
+		{"division by zero is zero", "duration_ms / queue_time_ms", ComputedNumber,
+			"if(toFloat64(queue_time_ms) = 0, 0, toFloat64(duration_ms) / toFloat64(queue_time_ms))", nil},
+		{"division params in order", "10 / 4", ComputedNumber,
+			"if(toFloat64(?) = 0, 0, toFloat64(?) / toFloat64(?))", []interface{}{4.0, 10.0, 4.0}},
+		{"KQL alias", "duration * 2", ComputedNumber,
+			"(toFloat64(duration_ms) * toFloat64(?))", []interface{}{2.0}},
+		{"form before the colon", "substr(form, 1, position(form, ':') - 1)", ComputedString,
+			"toString(substringUTF8(form, toInt64(toFloat64(?)), toInt64((toFloat64(positionUTF8(form, ?)) - toFloat64(?)))))",
+			[]interface{}{1.0, ":", 1.0}},
+		{"upper", "UPPER(user)", ComputedString, "toString(upperUTF8(user))", nil},
+		{"lower", "lower(queue)", ComputedString, "toString(lowerUTF8(queue))", nil},
+		{"length", "length(error_message)", ComputedNumber, "toFloat64(lengthUTF8(error_message))", nil},
+		{"concat numbers", "concat(user, '/', duration_ms)", ComputedString,
+			"toString(concat(user, ?, toString(toFloat64(duration_ms))))", []interface{}{"/"}},
+		{"string comparison", `log_type == "API"`, ComputedBool, "toUInt8((toString(log_type) = ?))", []interface{}{"API"}},
+		{"bool comparison", "success != (duration_ms > 10)", ComputedBool,
+			"toUInt8((success != (toFloat64(duration_ms) > toFloat64(?))))", []interface{}{10.0}},
+		{"not equal alias", "user <> 'Demo'", ComputedBool, "toUInt8((user != ?))", []interface{}{"Demo"}},
+		{"escaped quote", `concat('it\'s ', user)`, ComputedString, "toString(concat(?, user))", []interface{}{"it's "}},
+		{"bare field", "success", ComputedBool, "toUInt8(success)", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			col := compileOne(t, tc.expr)
+			assert.Equal(t, tc.typ, col.Type)
+			assert.Equal(t, tc.sql, col.SQL)
+			assert.Equal(t, tc.params, col.Params)
+			assert.Equal(t, len(tc.params), strings.Count(col.SQL, "?"), "one placeholder per parameter")
+		})
+	}
+}
+
+func TestCompileComputed_LiteralsAreParameters(t *testing.T) {
+	col := compileOne(t, `concat(user, '; DROP TABLE log_entries --')`)
+	assert.NotContains(t, col.SQL, "DROP")
+	assert.Equal(t, []interface{}{"; DROP TABLE log_entries --"}, col.Params)
+}
+
+func TestCompileComputed_Columns(t *testing.T) {
+	cols, err := CompileComputed([]ComputedColumn{
+		{Name: "total_ms", Expr: "duration_ms + queue_time_ms"},
+		{Name: "is_slow", Expr: "duration_ms > 2000"},
+	})
+	require.NoError(t, err)
+	require.Len(t, cols, 2)
+	assert.Equal(t, "total_ms", cols[0].Name)
+	assert.Equal(t, "is_slow", cols[1].Name)
+
+	cols, err = CompileComputed(nil)
+	require.NoError(t, err)
+	assert.Empty(t, cols)
+}
+
+// ---------------------------------------------------------------------------
+// CompileComputed - rejection
+// ---------------------------------------------------------------------------
+
+func TestCompileComputed_RejectsExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"empty", "  ", "empty expression"},
+		{"too long", strings.Repeat("1+", 200) + "1", "longer than"},
+		{"too complex", strings.TrimSuffix(strings.Repeat("duration_ms+", 20), "+"), "more than 32 terms"},
+
+		// Functions outside the whitelist.
+		{"sleep", "sleep(3)", `unknown function "sleep"`},
+		{"file", "file('/etc/passwd')", `unknown function "file"`},
+		{"url", "url('http://x', CSV)", `unknown function "url"`},
+		{"toString", "toString(duration_ms)", `unknown function "toString"`},
+		{"currentUser", "currentUser()", `unknown function "currentUser"`},
+		{"dictGet", "dictGet('d', 'a', 1)", `unknown function "dictGet"`},
+		{"nested unknown", "upper(reverse(user))", `unknown function "reverse"`},
+
+		// Subqueries and SQL.
+		{"subquery", "(SELECT 1)", `unknown field "SELECT"`},
+		{"select in call", "upper(SELECT user FROM log_entries)", `unknown field "SELECT"`},
+		{"statement", "1; DROP TABLE log_entries", `unexpected character ";"`},
+		{"comment", "duration_ms -- x", `unknown field "x"`},
+		{"block comment", "duration_ms /* x */", `unexpected "*"`},
+		{"backquoted identifier", "`tenant_id`", "unexpected character \"`\""},
+		{"qualified column", "log_entries.user", `unknown field "log_entries"`},
+		{"placeholder", "user = ?", `unexpected character "?"`},
+		{"named parameter", "user = @tenantID", `unexpected character "@"`},
+		{"array", "[1, 2]", `unexpected character "["`},
+		{"settings", "duration_ms SETTINGS max_threads = 1", `unexpected "SETTINGS"`},
+
+		// Columns outside the whitelist.
+		{"tenant column", "tenant_id", `unknown field "tenant_id"`},
+		{"job column", "concat(job_id, user)", `unknown field "job_id"`},
+		{"raw text", "length(raw_text)", `unknown field "raw_text"`},
+		{"sql statement", "upper(sql_statement)", `unknown field "sql_statement"`},
+		{"unknown column", "secret_column + 1", `unknown field "secret_column"`},
+
+		// Types.
+		{"string arithmetic", "user + 'x'", "needs numbers"},
+		{"bool arithmetic", "success * 2", "needs numbers"},
+		{"negated string", "-user", "needs a number"},
+		{"mixed comparison", "user = 1", "cannot compare string with number"},
+		{"ordered bools", "success > success", "does not apply to bool"},
+		{"chained comparison", "1 < duration_ms < 3", "cannot be chained"},
+		{"upper of number", "upper(duration_ms)", "argument 1 of upper cannot be a number"},
+		{"substr string offset", "substr(user, '1')", "argument 2 of substr cannot be a string"},
+		{"concat bool", "concat(user, success)", "argument 2 of concat cannot be a bool"},
+		{"too few arguments", "substr(user)", "substr takes 2 to 3 arguments, got 1"},
+		{"too many arguments", "upper(user, form)", "upper takes 1 arguments, got 2"},
+
+		// Syntax.
+		{"unterminated string", "concat(user, 'x)", "unterminated string"},
+		{"unclosed parenthesis", "(1 + 2", "missing closing parenthesis"},
+		{"unclosed call", "upper(user", "missing closing parenthesis of upper"},
+		{"trailing operator", "duration_ms +", "unexpected end"},
+		{"dangling comma", "concat(user,)", `unexpected ")"`},
+		{"bad number", "1.2.3", `invalid number "1.2.3"`},
+		{"two terms", "duration_ms queue_time_ms", `unexpected "queue_time_ms"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CompileComputed([]ComputedColumn{{Name: "c", Expr: tc.expr}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+			assert.Contains(t, err.Error(), `computed column "c"`)
+		})
+	}
+}
+
+func TestCompileComputed_RejectsColumns(t *testing.T) {
+	tooMany := make([]ComputedColumn, MaxComputedColumns+1)
+	for i := range tooMany {
+		tooMany[i] = ComputedColumn{Name: fmt.Sprintf("c%d", i), Expr: "1"}
+	}
+
+	tests := []struct {
+		name string
+		cols []ComputedColumn
+		want string
+	}{
+		{"too many", tooMany, "at most 5 computed columns"},
+		{"empty name", []ComputedColumn{{Name: "", Expr: "1"}}, "must be 1 to 32 characters"},
+		{"long name", []ComputedColumn{{Name: strings.Repeat("a", 33), Expr: "1"}}, "must be 1 to 32 characters"},
+		{"upper-case name", []ComputedColumn{{Name: "Total", Expr: "1"}}, "invalid computed column name"},
+		{"leading digit", []ComputedColumn{{Name: "1x", Expr: "1"}}, "invalid computed column name"},
+		{"injection in name", []ComputedColumn{{Name: "x FROM t --", Expr: "1"}}, "invalid computed column name"},
+		{"shadows a column", []ComputedColumn{{Name: "duration_ms", Expr: "1"}}, "is a field name"},
+		{"shadows a KQL field", []ComputedColumn{{Name: "duration", Expr: "1"}}, "is a field name"},
+		{"duplicate", []ComputedColumn{{Name: "x", Expr: "1"}, {Name: "x", Expr: "2"}}, "defined twice"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CompileComputed(tc.cols)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package search
+
+import "strings"
+
+// FreeTextMatch is how a free-text term matches log entries.
+type FreeTextMatch string
+
+const (
+	// MatchWord matches whole words of raw_text and error_message. Their
+	// token bloom filter indexes skip the granules without the word.
+	MatchWord FreeTextMatch = "word"
+
+	// MatchSubstring matches anywhere in the text fields of an entry, by
+	// scanning them.
+	MatchSubstring FreeTextMatch = "substring"
+)
+
+// Notes of a QueryInterpretation.
+const (
+	wordMatchNote = "Words match whole words of the log text and error message, so timeout does not match timeouts; quote a word to match it anywhere."
+	allTermsNote  = "Terms not joined by OR must all match."
+)
+
+// InterpretedTerm is a free-text term of a query and how it matches.
+type InterpretedTerm struct {
+	Term  string        `json:"term"`
+	Match FreeTextMatch `json:"match"`
+}
+
+// QueryInterpretation tells the user how the free text of their query was
+// matched.
+type QueryInterpretation struct {
+	Terms []InterpretedTerm `json:"terms"`
+	Notes []string          `json:"notes,omitempty"`
+}
+
+// IsPlainWord reports whether term is a single ClickHouse token: ASCII
+// letters and digits only. Plain words are matched with hasToken; any
+// other character is a token separator there.
+func IsPlainWord(term string) bool {
+	if term == "" {
+		return false
+	}
+	for i := 0; i < len(term); i++ {
+		c := term[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// freeTextSQL returns the condition of an unquoted free-text term: a plain
+// word matches whole words, case-insensitively, and any other term
+// matches as a substring.
+func freeTextSQL(term string) (string, []interface{}) {
+	if !IsPlainWord(term) {
+		return substringSQL(term)
+	}
+	// The indexes are built on the lowercased columns, so the condition
+	// lowercases them the same way for the indexes to apply.
+	word := strings.ToLower(term)
+	return "(hasToken(lower(raw_text), ?) OR hasToken(lower(error_message), ?))", []interface{}{word, word}
+}
+
+// substringSQL returns the condition matching term anywhere in the text
+// fields of an entry.
+func substringSQL(term string) (string, []interface{}) {
+	escaped := "%" + escapeLikePattern(term) + "%"
+	return "(raw_text ILIKE ? OR error_message ILIKE ? OR user ILIKE ? OR form ILIKE ? OR api_code ILIKE ? OR filter_name ILIKE ? OR esc_name ILIKE ?)",
+		[]interface{}{escaped, escaped, escaped, escaped, escaped, escaped, escaped}
+}
+
+// FreeTextWhere returns the condition matching text, a query that is not
+// valid KQL, as free text: each of its whitespace-separated terms must
+// match.
+func FreeTextWhere(text string) (string, []interface{}) {
+	terms := strings.Fields(text)
+	if len(terms) == 0 {
+		return "1=1", nil
+	}
+	conds := make([]string, 0, len(terms))
+	var params []interface{}
+	for _, term := range terms {
+		cond, p := freeTextSQL(term)
+		conds = append(conds, cond)
+		params = append(params, p...)
+	}
+	return strings.Join(conds, " AND "), params
+}
+
+// Interpret returns how the free-text terms of node match, or nil when it
+// has none.
+func Interpret(node *QueryNode) *QueryInterpretation {
+	var terms []InterpretedTerm
+	var walk func(n *QueryNode)
+	walk = func(n *QueryNode) {
+		if n == nil {
+			return
+		}
+		if !n.IsLeaf() {
+			for _, child := range n.Children {
+				walk(child)
+			}
+			return
+		}
+		if n.Op != OpFullText {
+			return
+		}
+		match := MatchSubstring
+		if !n.Quoted && IsPlainWord(n.Value) {
+			match = MatchWord
+		}
+		terms = append(terms, InterpretedTerm{Term: n.Value, Match: match})
+	}
+	walk(node)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	qi := &QueryInterpretation{Terms: terms}
+	for _, t := range terms {
+		if t.Match == MatchWord {
+			qi.Notes = append(qi.Notes, wordMatchNote)
+			break
+		}
+	}
+	if len(terms) > 1 {
+		qi.Notes = append(qi.Notes, allTermsNote)
+	}
+	return qi
+}
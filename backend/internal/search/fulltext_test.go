@@ -0,0 +1,121 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPlainWord(t *testing.T) {
+	for term, want := range map[string]bool{
+		"timeout":   true,
+		"ARERR302":  true,
+		"42":        true,
+		"":          false,
+		"ARERR-302": false,
+		"a.b":       false,
+		"café":      false,
+		"50%":       false,
+	} {
+		assert.Equal(t, want, IsPlainWord(term), term)
+	}
+}
+
+func TestFreeText_Predicates(t *testing.T) {
+	tests := []struct {
+		name   string
+		kql    string
+		sql    string
+		params []interface{}
+	}{
+		{
+			name:   "word",
+			kql:    "Timeout",
+			sql:    "(hasToken(lower(raw_text), ?) OR hasToken(lower(error_message), ?))",
+			params: []interface{}{"timeout", "timeout"},
+		},
+		{
+			name:   "quoted word",
+			kql:    `"timeout"`,
+			sql:    substringCond,
+			params: repeat("%timeout%", 7),
+		},
+		{
+			name:   "phrase",
+			kql:    `"connection refused"`,
+			sql:    substringCond,
+			params: repeat("%connection refused%", 7),
+		},
+		{
+			name:   "special characters",
+			kql:    "ARERR-302",
+			sql:    substringCond,
+			params: repeat("%ARERR-302%", 7),
+		},
+		{
+			name:   "mixed",
+			kql:    `timeout "ARERR 302"`,
+			sql:    "((hasToken(lower(raw_text), ?) OR hasToken(lower(error_message), ?)) AND " + substringCond + ")",
+			params: append([]interface{}{"timeout", "timeout"}, repeat("%ARERR 302%", 7)...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseKQL(tt.kql)
+			require.NoError(t, err)
+			sql, params := node.ToClickHouseWhere()
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.params, params)
+		})
+	}
+}
+
+func TestFreeTextWhere(t *testing.T) {
+	sql, params := FreeTextWhere("  ")
+	assert.Equal(t, "1=1", sql)
+	assert.Empty(t, params)
+
+	sql, params = FreeTextWhere("timeout user:demo")
+	assert.Equal(t, "(hasToken(lower(raw_text), ?) OR hasToken(lower(error_message), ?)) AND "+substringCond, sql)
+	assert.Equal(t, append([]interface{}{"timeout", "timeout"}, repeat("%user:demo%", 7)...), params)
+}
+
+func TestInterpret(t *testing.T) {
+	node, err := ParseKQL("type:API AND duration:>1000")
+	require.NoError(t, err)
+	assert.Nil(t, Interpret(node))
+
+	node, err = ParseKQL("timeout")
+	require.NoError(t, err)
+	assert.Equal(t, &QueryInterpretation{
+		Terms: []InterpretedTerm{{Term: "timeout", Match: MatchWord}},
+		Notes: []string{wordMatchNote},
+	}, Interpret(node))
+
+	node, err = ParseKQL(`"connection refused"`)
+	require.NoError(t, err)
+	assert.Equal(t, &QueryInterpretation{
+		Terms: []InterpretedTerm{{Term: "connection refused", Match: MatchSubstring}},
+	}, Interpret(node))
+
+	node, err = ParseKQL(`type:API timeout "ARERR 302"`)
+	require.NoError(t, err)
+	assert.Equal(t, &QueryInterpretation{
+		Terms: []InterpretedTerm{
+			{Term: "timeout", Match: MatchWord},
+			{Term: "ARERR 302", Match: MatchSubstring},
+		},
+		Notes: []string{wordMatchNote, allTermsNote},
+	}, Interpret(node))
+}
+
+const substringCond = "(raw_text ILIKE ? OR error_message ILIKE ? OR user ILIKE ? OR form ILIKE ? OR api_code ILIKE ? OR filter_name ILIKE ? OR esc_name ILIKE ?)"
+
+func repeat(v interface{}, n int) []interface{} {
+	out := make([]interface{}, n)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
@@ -40,6 +40,10 @@ type QueryNode struct {
 	Op    FilterOp `json:"op,omitempty"`
 	Value string   `json:"value,omitempty"`
 
+	// Quoted is set on free-text terms written in quotes, which match
+	// anywhere in the text rather than as whole words.
+	Quoted bool `json:"quoted,omitempty"`
+
 	// For branch nodes (boolean combinations).
 	BoolOp   BoolOp       `json:"bool_op,omitempty"`
 	Children []*QueryNode `json:"children,omitempty"`
@@ -63,6 +67,8 @@ var KnownFields = map[string]string{
 	"duration":   "duration_ms",
 	"status":     "success",
 	"api_code":   "api_code",
+	"client":     "client",
+	"client_ip":  "client_ip",
 	"sql_table":  "sql_table",
 	"filter":     "filter_name",
 	"escalation": "esc_name",
@@ -108,8 +114,9 @@ const (
 )
 
 type token struct {
-	kind tokenKind
-	val  string
+	kind   tokenKind
+	val    string
+	quoted bool // a tokWord written in quotes
 }
 
 func (t token) String() string { return t.val }
@@ -139,7 +146,7 @@ func tokenize(input string) ([]token, error) {
 			if j >= len(runes) {
 				return nil, fmt.Errorf("unterminated quoted string starting at position %d", i)
 			}
-			tokens = append(tokens, token{kind: tokWord, val: string(runes[i+1 : j])})
+			tokens = append(tokens, token{kind: tokWord, val: string(runes[i+1 : j]), quoted: true})
 			i = j + 1
 			continue
 		}
@@ -379,8 +386,9 @@ func (p *parser) parseAtom() (*QueryNode, error) {
 		// Bare word -- fulltext search.
 		p.advance()
 		return &QueryNode{
-			Op:    OpFullText,
-			Value: t.val,
+			Op:     OpFullText,
+			Value:  t.val,
+			Quoted: t.quoted,
 		}, nil
 	}
 
@@ -518,11 +526,12 @@ func (q *QueryNode) toSQL() (string, []interface{}) {
 		}
 	}
 
-	// Leaf: fulltext — search across multiple fields for broader results.
+	// Leaf: fulltext.
 	if q.Op == OpFullText {
-		escaped := "%" + escapeLikePattern(q.Value) + "%"
-		return "(raw_text ILIKE ? OR error_message ILIKE ? OR user ILIKE ? OR form ILIKE ? OR api_code ILIKE ? OR filter_name ILIKE ? OR esc_name ILIKE ?)",
-			[]interface{}{escaped, escaped, escaped, escaped, escaped, escaped, escaped}
+		if q.Quoted {
+			return substringSQL(q.Value)
+		}
+		return freeTextSQL(q.Value)
 	}
 
 	// Resolve column name.
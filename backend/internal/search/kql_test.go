@@ -562,7 +562,14 @@ func TestToClickHouseWhere_Wildcard(t *testing.T) {
 }
 
 func TestToClickHouseWhere_FullText(t *testing.T) {
-	node := &QueryNode{Op: OpFullText, Value: "error"}
+	node := &QueryNode{Op: OpFullText, Value: "Error"}
+	sql, params := node.ToClickHouseWhere()
+	assert.Equal(t, "(hasToken(lower(raw_text), ?) OR hasToken(lower(error_message), ?))", sql)
+	assert.Equal(t, []interface{}{"error", "error"}, params)
+}
+
+func TestToClickHouseWhere_FullTextQuoted(t *testing.T) {
+	node := &QueryNode{Op: OpFullText, Value: "error", Quoted: true}
 	sql, params := node.ToClickHouseWhere()
 	assert.Contains(t, sql, "raw_text ILIKE ?")
 	assert.Contains(t, sql, "error_message ILIKE ?")
@@ -687,6 +694,12 @@ func TestEndToEnd_ParseAndGenerate(t *testing.T) {
 		{
 			name:        "fulltext",
 			kql:         "error",
+			expectedSQL: "(hasToken(lower(raw_text), ?) OR hasToken(lower(error_message), ?))",
+			paramCount:  2,
+		},
+		{
+			name:        "quoted fulltext",
+			kql:         `"connection refused"`,
 			expectedSQL: "(raw_text ILIKE ? OR error_message ILIKE ? OR user ILIKE ? OR form ILIKE ? OR api_code ILIKE ? OR filter_name ILIKE ? OR esc_name ILIKE ?)",
 			paramCount:  7,
 		},
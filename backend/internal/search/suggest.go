@@ -0,0 +1,180 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SuggestMinResults is the result count below which no refinements are
+// suggested: a few hundred entries are read, not narrowed down.
+const SuggestMinResults = 200
+
+const (
+	maxSuggestions         = 5
+	maxSuggestionsPerField = 2
+
+	// maxRefinementShare is the share of the results above which a
+	// refinement hardly narrows them.
+	maxRefinementShare = 0.95
+)
+
+// facetFields maps the columns facets are counted on to their KQL field.
+var facetFields = map[string]string{
+	"log_type":  "type",
+	"user":      "user",
+	"queue":     "queue",
+	"client_ip": "client_ip",
+}
+
+// FacetCount is the number of results with a value of a facet.
+type FacetCount struct {
+	Value string
+	Count int64
+}
+
+// DurationCount is the number of results slower than ThresholdMS.
+type DurationCount struct {
+	ThresholdMS int64
+	Count       int64
+}
+
+// RefinementInput is what is known of the results of a search: their
+// count, the top values of each facet column and the counts kept by the
+// failure and duration refinements.
+type RefinementInput struct {
+	Query      string
+	Total      int64
+	Facets     map[string][]FacetCount // by column
+	Failed     int64
+	SlowerThan []DurationCount
+}
+
+// Suggestion is a refinement of a search: a KQL snippet to append to the
+// query and the number of results it is predicted to keep.
+type Suggestion struct {
+	Field          string  `json:"field"`
+	KQL            string  `json:"kql"`
+	Query          string  `json:"query"` // the current query with KQL appended
+	PredictedCount int64   `json:"predicted_count"`
+	Share          float64 `json:"share"`
+	Reason         string  `json:"reason"`
+}
+
+// SuggestRefinements ranks the refinements of a search by how well they
+// split its results: the binary entropy of the share of results each keeps,
+// so that a refinement halving the results ranks first and one keeping all
+// or almost none of them last. Refinements keeping no result, or more than
+// 95% of them, are left out. At most two refinements of a field and five in
+// all are returned, none below SuggestMinResults results.
+func SuggestRefinements(in RefinementInput) []Suggestion {
+	if in.Total < SuggestMinResults {
+		return nil
+	}
+
+	var candidates []Suggestion
+	add := func(field, kql string, count int64, reason string) {
+		if count <= 0 || float64(count) > maxRefinementShare*float64(in.Total) {
+			return
+		}
+		candidates = append(candidates, Suggestion{
+			Field:          field,
+			KQL:            kql,
+			Query:          appendKQL(in.Query, kql),
+			PredictedCount: count,
+			Share:          float64(count) / float64(in.Total),
+			Reason:         reason,
+		})
+	}
+
+	for column, values := range in.Facets {
+		field, ok := facetFields[column]
+		if !ok {
+			continue
+		}
+		for _, v := range values {
+			value, ok := kqlValue(v.Value)
+			if !ok {
+				continue
+			}
+			kql := field + ":" + value
+			reason := fmt.Sprintf("%s keeps %d of the %d results", kql, v.Count, in.Total)
+			if 2*v.Count >= in.Total {
+				reason = fmt.Sprintf("%d%% of these results are %s", percent(v.Count, in.Total), kql)
+			}
+			add(field, kql, v.Count, reason)
+		}
+	}
+	add("status", "status:false", in.Failed, fmt.Sprintf("%d of these results failed", in.Failed))
+	for _, d := range in.SlowerThan {
+		kql := fmt.Sprintf("duration:>%d", d.ThresholdMS)
+		add("duration", kql, d.Count, fmt.Sprintf("add %s to keep only the %d slowest", kql, d.Count))
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ea, eb := splitEntropy(a.Share), splitEntropy(b.Share); ea != eb {
+			return ea > eb
+		}
+		if a.PredictedCount != b.PredictedCount {
+			return a.PredictedCount < b.PredictedCount
+		}
+		return a.KQL < b.KQL
+	})
+
+	var out []Suggestion
+	perField := make(map[string]int)
+	for _, c := range candidates {
+		if perField[c.Field] >= maxSuggestionsPerField {
+			continue
+		}
+		perField[c.Field]++
+		out = append(out, c)
+		if len(out) == maxSuggestions {
+			break
+		}
+	}
+	return out
+}
+
+// splitEntropy is the binary entropy of splitting results into a share p
+// and the rest.
+func splitEntropy(p float64) float64 {
+	if p <= 0 || p >= 1 {
+		return 0
+	}
+	return -p*math.Log2(p) - (1-p)*math.Log2(1-p)
+}
+
+func percent(n, total int64) int64 {
+	return int64(math.Round(100 * float64(n) / float64(total)))
+}
+
+// kqlValue writes a facet value as a KQL value, quoted unless it is a bare
+// word. Values that KQL cannot match exactly, empty ones and those holding
+// a quote or a wildcard, are refused.
+func kqlValue(v string) (string, bool) {
+	if v == "" || strings.ContainsAny(v, `"*`) {
+		return "", false
+	}
+	for _, r := range v {
+		if !isWordChar(r) {
+			return `"` + v + `"`, true
+		}
+	}
+	return v, true
+}
+
+// appendKQL returns query narrowed by the KQL snippet. A query whose top
+// level is an OR is parenthesized, as the implicit AND binds tighter.
+func appendKQL(query, kql string) string {
+	query = strings.TrimSpace(query)
+	if query == "" || query == "*" {
+		return kql
+	}
+	if node, err := ParseKQL(query); err == nil && node != nil && node.BoolOp == BoolOr {
+		return "(" + query + ") " + kql
+	}
+	return query + " " + kql
+}
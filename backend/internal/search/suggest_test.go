@@ -0,0 +1,97 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestRefinements_RanksBySplit(t *testing.T) {
+	in := RefinementInput{
+		Query: "type:API",
+		Total: 10000,
+		Facets: map[string][]FacetCount{
+			"user": {
+				{Value: "IntegrationSvc", Count: 9000},
+				{Value: "Demo User", Count: 600},
+				{Value: "jsmith", Count: 5},
+			},
+			"log_type": {{Value: "API", Count: 10000}},
+			"queue":    {{Value: "Fast", Count: 4800}},
+			"form":     {{Value: "HPD:Help Desk", Count: 5000}},
+		},
+		Failed:     120,
+		SlowerThan: []DurationCount{{ThresholdMS: 500, Count: 1100}, {ThresholdMS: 5000, Count: 0}},
+	}
+
+	got := SuggestRefinements(in)
+	require.Len(t, got, 5)
+
+	assert.Equal(t, "queue:Fast", got[0].KQL, "the most even split ranks first")
+	assert.Equal(t, "type:API queue:Fast", got[0].Query)
+	assert.EqualValues(t, 4800, got[0].PredictedCount)
+	assert.InDelta(t, 0.48, got[0].Share, 1e-9)
+	assert.Equal(t, "queue:Fast keeps 4800 of the 10000 results", got[0].Reason)
+
+	var kqls []string
+	for _, s := range got {
+		kqls = append(kqls, s.KQL)
+	}
+	assert.Equal(t, []string{"queue:Fast", "duration:>500", "user:IntegrationSvc", "user:\"Demo User\"", "status:false"}, kqls)
+
+	assert.Equal(t, "90% of these results are user:IntegrationSvc", got[2].Reason)
+	assert.Equal(t, "add duration:>500 to keep only the 1100 slowest", got[1].Reason)
+	assert.Equal(t, "120 of these results failed", got[4].Reason)
+}
+
+func TestSuggestRefinements_LeavesOut(t *testing.T) {
+	in := RefinementInput{
+		Total: 1000,
+		Facets: map[string][]FacetCount{
+			"user": {
+				{Value: "everyone", Count: 990},  // keeps almost all results
+				{Value: "wild*card", Count: 400}, // cannot be matched exactly
+				{Value: `say "hi"`, Count: 300},  // cannot be quoted
+				{Value: "", Count: 200},          // empty
+				{Value: "a", Count: 100},
+				{Value: "b", Count: 90},
+				{Value: "c", Count: 80}, // a third of the field
+			},
+		},
+		SlowerThan: []DurationCount{{ThresholdMS: 100, Count: 0}},
+	}
+
+	got := SuggestRefinements(in)
+	require.Len(t, got, 2)
+	assert.Equal(t, "user:a", got[0].KQL)
+	assert.Equal(t, "user:a", got[0].Query, "the match-all query is replaced")
+	assert.Equal(t, "user:b", got[1].KQL)
+}
+
+func TestSuggestRefinements_SmallResultSet(t *testing.T) {
+	got := SuggestRefinements(RefinementInput{
+		Total:  SuggestMinResults - 1,
+		Facets: map[string][]FacetCount{"user": {{Value: "a", Count: 100}}},
+	})
+	assert.Nil(t, got)
+}
+
+func TestAppendKQL(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"", "user:a"},
+		{"*", "user:a"},
+		{"type:API", "type:API user:a"},
+		{"type:API OR type:SQL", "(type:API OR type:SQL) user:a"},
+		{"(type:API OR type:SQL) AND duration:>10", "(type:API OR type:SQL) AND duration:>10 user:a"},
+	}
+	for _, tt := range tests {
+		got := appendKQL(tt.query, "user:a")
+		assert.Equal(t, tt.want, got, tt.query)
+
+		_, err := ParseKQL(got)
+		assert.NoError(t, err, got)
+	}
+}
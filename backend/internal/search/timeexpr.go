@@ -0,0 +1,181 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeExprHelp describes the accepted time expressions, for error messages.
+const TimeExprHelp = "expected an RFC3339 timestamp or capture_start, capture_end or capture_<N>% with an optional +/- offset, e.g. capture_end-15m"
+
+// timeAnchor is the point of the capture a relative time expression is
+// measured from.
+type timeAnchor int
+
+const (
+	anchorAbsolute timeAnchor = iota
+	anchorStart
+	anchorEnd
+	anchorPercent
+)
+
+// TimeExpr is a time bound of a request: an absolute timestamp, or a point
+// of the capture of the job queried, resolved once the capture's time
+// range is known.
+//
+// The grammar, case-insensitive and with optional whitespace around the
+// sign:
+//
+//	expr     = rfc3339 | anchor [ ("+" | "-") duration ]
+//	anchor   = "capture_start" | "capture_end" | "capture_" percent "%"
+//	percent  = number between 0 and 100, e.g. 25 or 12.5
+//	duration = one or more number and unit pairs, units ms, s, m, h, d; e.g. 1h30m
+//
+// Relative expressions are clamped to the capture's time range, so that
+// capture_end+1h is the end of the capture; absolute timestamps are used as
+// given.
+type TimeExpr struct {
+	raw     string
+	abs     time.Time
+	anchor  timeAnchor
+	percent float64
+	offset  time.Duration
+}
+
+// ParseTimeExpr parses a time expression.
+func ParseTimeExpr(s string) (*TimeExpr, error) {
+	raw := strings.TrimSpace(s)
+	if raw == "" {
+		return nil, fmt.Errorf("empty time expression; %s", TimeExprHelp)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return &TimeExpr{raw: raw, abs: t}, nil
+	}
+
+	e := &TimeExpr{raw: raw}
+	lower := strings.ToLower(raw)
+	rest, ok := strings.CutPrefix(lower, "capture_")
+	if !ok {
+		return nil, fmt.Errorf("%q is not a timestamp or capture expression; %s", raw, TimeExprHelp)
+	}
+
+	// The anchor runs up to the sign of the offset, if any.
+	anchor, offset := rest, ""
+	if i := strings.IndexAny(rest, "+-"); i >= 0 {
+		anchor, offset = rest[:i], rest[i:]
+	}
+	switch anchor = strings.TrimSpace(anchor); {
+	case anchor == "start":
+		e.anchor = anchorStart
+	case anchor == "end":
+		e.anchor = anchorEnd
+	case strings.HasSuffix(anchor, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(anchor, "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("%q: capture percentage must be between 0 and 100", raw)
+		}
+		e.anchor, e.percent = anchorPercent, pct
+	default:
+		return nil, fmt.Errorf("%q: unknown capture anchor %q; %s", raw, "capture_"+anchor, TimeExprHelp)
+	}
+
+	if offset != "" {
+		sign := time.Duration(1)
+		if offset[0] == '-' {
+			sign = -1
+		}
+		d, err := parseOffset(strings.TrimSpace(offset[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", raw, err)
+		}
+		e.offset = sign * d
+	}
+	return e, nil
+}
+
+// offsetUnits are the units of an offset, longest first so that "ms" is
+// not read as minutes.
+var offsetUnits = []struct {
+	name string
+	unit time.Duration
+}{
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+}
+
+// parseOffset parses the duration of an offset: number and unit pairs such
+// as 15m or 1h30m.
+func parseOffset(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing offset duration after the sign")
+	}
+	var total time.Duration
+	for s != "" {
+		n := 0
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("invalid offset duration: expected a number at %q", s)
+		}
+		value, err := strconv.ParseInt(s[:n], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset duration: %w", err)
+		}
+		s = s[n:]
+
+		matched := false
+		for _, u := range offsetUnits {
+			if strings.HasPrefix(s, u.name) {
+				total += time.Duration(value) * u.unit
+				s = s[len(u.name):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("invalid offset duration: missing or unknown unit at %q (units ms, s, m, h, d)", s)
+		}
+	}
+	return total, nil
+}
+
+// Relative reports whether the expression needs the capture's time range.
+func (e *TimeExpr) Relative() bool {
+	return e.anchor != anchorAbsolute
+}
+
+// Resolve returns the time the expression stands for within a capture
+// spanning start to end. Relative expressions are clamped to that range.
+func (e *TimeExpr) Resolve(start, end time.Time) time.Time {
+	var t time.Time
+	switch e.anchor {
+	case anchorAbsolute:
+		return e.abs
+	case anchorStart:
+		t = start
+	case anchorEnd:
+		t = end
+	case anchorPercent:
+		span := end.Sub(start)
+		t = start.Add(time.Duration(float64(span) * e.percent / 100)).Truncate(time.Millisecond)
+	}
+	t = t.Add(e.offset)
+	if t.Before(start) {
+		return start
+	}
+	if t.After(end) {
+		return end
+	}
+	return t
+}
+
+// String returns the expression as given.
+func (e *TimeExpr) String() string {
+	return e.raw
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeExpr_Resolve(t *testing.T) {
+	start := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+
+	tests := []struct {
+		expr     string
+		want     time.Time
+		relative bool
+	}{
+		{"2026-03-10T09:30:00Z", start.Add(90 * time.Minute), false},
+		{"2026-03-10T09:30:00.250+00:00", start.Add(90*time.Minute + 250*time.Millisecond), false},
+		{"2026-03-10T05:00:00Z", start.Add(-3 * time.Hour), false}, // absolute times are not clamped
+		{"capture_start", start, true},
+		{"capture_end", end, true},
+		{"CAPTURE_END", end, true},
+		{"  capture_start  ", start, true},
+		{"capture_start+1h", start.Add(time.Hour), true},
+		{"capture_end-15m", end.Add(-15 * time.Minute), true},
+		{"capture_end - 15m", end.Add(-15 * time.Minute), true},
+		{"capture_start+1h30m", start.Add(90 * time.Minute), true},
+		{"capture_start+90s", start.Add(90 * time.Second), true},
+		{"capture_start+500ms", start.Add(500 * time.Millisecond), true},
+		{"Capture_Start+1H", start.Add(time.Hour), true},
+		{"capture_25%", start.Add(time.Hour), true},
+		{"capture_12.5%", start.Add(30 * time.Minute), true},
+		{"capture_0%", start, true},
+		{"capture_100%", end, true},
+		{"capture_50%+10m", start.Add(2*time.Hour + 10*time.Minute), true},
+		// Offsets beyond the capture clamp to its bounds.
+		{"capture_end+1h", end, true},
+		{"capture_start-1d", start, true},
+		{"capture_end-5h", start, true},
+		{"capture_start+2d", end, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := ParseTimeExpr(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.relative, e.Relative())
+			assert.True(t, tt.want.Equal(e.Resolve(start, end)), "got %s, want %s", e.Resolve(start, end), tt.want)
+		})
+	}
+}
+
+func TestParseTimeExpr_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{"", "empty time expression"},
+		{"   ", "empty time expression"},
+		{"yesterday", "is not a timestamp or capture expression"},
+		{"2026-03-10 09:30", "is not a timestamp or capture expression"},
+		{"capture_middle", "unknown capture anchor"},
+		{"capture_", "unknown capture anchor"},
+		{"capture_150%", "between 0 and 100"},
+		{"capture_-5%", "unknown capture anchor"},
+		{"capture_x%", "between 0 and 100"},
+		{"capture_end-", "missing offset duration"},
+		{"capture_end-15", "missing or unknown unit"},
+		{"capture_end-15w", "missing or unknown unit"},
+		{"capture_end-m", "expected a number"},
+		{"capture_end-1h-5m", "expected a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseTimeExpr(tt.expr)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestTimeExpr_String(t *testing.T) {
+	e, err := ParseTimeExpr(" capture_end-15m ")
+	require.NoError(t, err)
+	assert.Equal(t, "capture_end-15m", e.String())
+}
@@ -0,0 +1,308 @@
+// Package sqlliteral finds the literal values of SQL statements as the AR
+// Server logs them, so that they can be replaced without touching the rest
+// of the statement: string literals in every quoting style the supported
+// databases use (N'...', E'...', X'...', $$...$$, with doubled or escaped
+// quotes), numbers and hexadecimal constants. Quoted identifiers, comments
+// and bind parameters are structure, never literals. A string left
+// unterminated, as in a statement cut short by the log, runs to the end of
+// the text.
+package sqlliteral
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Kind is the kind of a literal.
+type Kind int
+
+const (
+	String Kind = iota // '...', N'...', E'...' and dollar-quoted strings
+	Number             // 42, 1.5, .5, 1e-3
+	Hex                // 0x1F and X'1F'
+)
+
+func (k Kind) String() string {
+	switch k {
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case Hex:
+		return "hex"
+	}
+	return "unknown"
+}
+
+// Literal is a literal of a statement. Text is the literal as written,
+// prefix and quotes included, at [Start, End) of the statement; Value is
+// the value it denotes, unquoted and unescaped.
+type Literal struct {
+	Kind       Kind
+	Start, End int
+	Text       string
+	Value      string
+}
+
+// Scan returns the literals of sql in order.
+func Scan(sql string) []Literal {
+	var lits []Literal
+	s := scanner{src: sql}
+	for s.pos < len(sql) {
+		if lit, ok := s.next(); ok {
+			lits = append(lits, lit)
+		}
+	}
+	return lits
+}
+
+// Replace returns sql with each literal replaced by what fn returns for
+// it. The text between literals is kept byte for byte.
+func Replace(sql string, fn func(Literal) string) string {
+	lits := Scan(sql)
+	if len(lits) == 0 {
+		return sql
+	}
+	var b strings.Builder
+	b.Grow(len(sql))
+	last := 0
+	for _, lit := range lits {
+		b.WriteString(sql[last:lit.Start])
+		b.WriteString(fn(lit))
+		last = lit.End
+	}
+	b.WriteString(sql[last:])
+	return b.String()
+}
+
+// Placeholder replaces a literal removed from a statement.
+const Placeholder = "'<redacted>'"
+
+// Remove replaces every literal with Placeholder.
+func Remove(Literal) string { return Placeholder }
+
+// hashLen is the number of hex digits of a hash token.
+const hashLen = 12
+
+// Hasher replaces every literal with a string token derived from its value
+// and key: equal values get equal tokens, whatever their quoting, so that
+// statements can still be grouped and compared. Short or guessable values,
+// such as small numbers, can be recovered by hashing candidates with the
+// same key; the tokens hide values, they do not encrypt them.
+func Hasher(key []byte) func(Literal) string {
+	return func(lit Literal) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(lit.Value))
+		return "'#" + hex.EncodeToString(mac.Sum(nil))[:hashLen] + "'"
+	}
+}
+
+type scanner struct {
+	src string
+	pos int
+}
+
+// next advances over one token and returns it when it is a literal.
+func (s *scanner) next() (Literal, bool) {
+	start := s.pos
+	c := s.src[start]
+	switch {
+	case c == '\'':
+		return s.quoted(start, start, String, false), true
+	case c == '"' || c == '`':
+		s.skipDelimited(start+1, c)
+	case c == '[':
+		// SQL Server quotes identifiers in brackets, and doubles a ] in them.
+		s.skipDelimited(start+1, ']')
+	case c == '-' && s.peek(1) == '-':
+		s.skipLine()
+	case c == '/' && s.peek(1) == '*':
+		s.skipBlockComment()
+	case (c == ':' || c == '$') && isDigit(s.peek(1)):
+		// Positional binds, :1 and $1.
+		s.pos++
+		s.skipWhile(isDigit)
+	case c == '$':
+		if tag, ok := s.dollarTag(start); ok {
+			return s.dollarQuoted(start, tag), true
+		}
+		s.pos++
+	case isDigit(c) || (c == '.' && isDigit(s.peek(1))):
+		return s.number(start)
+	case isIdentStart(c):
+		return s.word(start)
+	default:
+		s.pos++
+	}
+	return Literal{}, false
+}
+
+func (s *scanner) peek(n int) byte {
+	if s.pos+n < len(s.src) {
+		return s.src[s.pos+n]
+	}
+	return 0
+}
+
+func (s *scanner) skipWhile(fn func(byte) bool) {
+	for s.pos < len(s.src) && fn(s.src[s.pos]) {
+		s.pos++
+	}
+}
+
+// skipDelimited skips a quoted identifier from its first character at i
+// to its closing delimiter, a doubled delimiter standing for itself.
+func (s *scanner) skipDelimited(i int, delim byte) {
+	for i < len(s.src) {
+		if s.src[i] == delim {
+			if i+1 < len(s.src) && s.src[i+1] == delim {
+				i += 2
+				continue
+			}
+			s.pos = i + 1
+			return
+		}
+		i++
+	}
+	s.pos = len(s.src)
+}
+
+func (s *scanner) skipLine() {
+	if i := strings.IndexByte(s.src[s.pos:], '\n'); i >= 0 {
+		s.pos += i + 1
+		return
+	}
+	s.pos = len(s.src)
+}
+
+func (s *scanner) skipBlockComment() {
+	if i := strings.Index(s.src[s.pos+2:], "*/"); i >= 0 {
+		s.pos += 2 + i + 2
+		return
+	}
+	s.pos = len(s.src)
+}
+
+// quoted scans the string literal whose quote is at q and whose prefix, if
+// any, starts at start. A doubled quote stands for one quote; with
+// backslashes set, as in E'...', a backslash escapes the next character.
+func (s *scanner) quoted(start, q int, kind Kind, backslashes bool) Literal {
+	var value strings.Builder
+	i := q + 1
+	for i < len(s.src) {
+		c := s.src[i]
+		switch {
+		case c == '\'' && i+1 < len(s.src) && s.src[i+1] == '\'':
+			value.WriteByte('\'')
+			i += 2
+		case c == '\'':
+			s.pos = i + 1
+			return s.literal(kind, start, value.String())
+		case c == '\\' && backslashes && i+1 < len(s.src):
+			value.WriteByte(s.src[i+1])
+			i += 2
+		default:
+			value.WriteByte(c)
+			i++
+		}
+	}
+	s.pos = len(s.src)
+	return s.literal(kind, start, value.String())
+}
+
+func (s *scanner) literal(kind Kind, start int, value string) Literal {
+	return Literal{Kind: kind, Start: start, End: s.pos, Text: s.src[start:s.pos], Value: value}
+}
+
+// dollarTag returns the tag of the PostgreSQL dollar quote opening at i,
+// empty for $$.
+func (s *scanner) dollarTag(i int) (string, bool) {
+	if i > 0 && isIdentPart(s.src[i-1]) {
+		return "", false
+	}
+	j := i + 1
+	for j < len(s.src) && isIdentPart(s.src[j]) && s.src[j] != '$' {
+		j++
+	}
+	if j >= len(s.src) || s.src[j] != '$' || (j > i+1 && isDigit(s.src[i+1])) {
+		return "", false
+	}
+	return s.src[i : j+1], true
+}
+
+func (s *scanner) dollarQuoted(start int, tag string) Literal {
+	body := start + len(tag)
+	end := strings.Index(s.src[body:], tag)
+	if end < 0 {
+		s.pos = len(s.src)
+		return s.literal(String, start, s.src[body:])
+	}
+	s.pos = body + end + len(tag)
+	return s.literal(String, start, s.src[body:body+end])
+}
+
+// number scans a number or hexadecimal constant. Digits running into
+// letters, as in a MySQL identifier starting with digits, are a word.
+func (s *scanner) number(start int) (Literal, bool) {
+	if s.src[start] == '0' && (s.peek(1) == 'x' || s.peek(1) == 'X') && isHexDigit(s.peek(2)) {
+		s.pos += 2
+		s.skipWhile(isHexDigit)
+		if s.pos < len(s.src) && isIdentPart(s.src[s.pos]) {
+			s.skipWhile(isIdentPart)
+			return Literal{}, false
+		}
+		return s.literal(Hex, start, strings.ToLower(s.src[start+2:s.pos])), true
+	}
+	s.skipWhile(isDigit)
+	if s.pos < len(s.src) && s.src[s.pos] == '.' {
+		s.pos++
+		s.skipWhile(isDigit)
+	}
+	if c := s.peek(0); c == 'e' || c == 'E' {
+		exp := 1
+		if sign := s.peek(1); sign == '+' || sign == '-' {
+			exp = 2
+		}
+		if isDigit(s.peek(exp)) {
+			s.pos += exp
+			s.skipWhile(isDigit)
+		}
+	}
+	if s.pos < len(s.src) && isIdentPart(s.src[s.pos]) {
+		s.skipWhile(isIdentPart)
+		return Literal{}, false
+	}
+	return s.literal(Number, start, s.src[start:s.pos]), true
+}
+
+// word scans an identifier or keyword, or the prefixed string literal it
+// introduces: N'...', E'...', X'...' and their combinations such as NX.
+func (s *scanner) word(start int) (Literal, bool) {
+	s.skipWhile(isIdentPart)
+	if s.pos >= len(s.src) || s.src[s.pos] != '\'' {
+		return Literal{}, false
+	}
+	prefix := strings.ToUpper(s.src[start:s.pos])
+	switch prefix {
+	case "N", "E":
+		return s.quoted(start, s.pos, String, prefix == "E"), true
+	case "X", "B":
+		lit := s.quoted(start, s.pos, Hex, false)
+		lit.Value = strings.ToLower(lit.Value)
+		return lit, true
+	}
+	return Literal{}, false
+}
+
+func isDigit(c byte) bool    { return c >= '0' && c <= '9' }
+func isHexDigit(c byte) bool { return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') }
+
+func isIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '#' || c == '@' || c >= 0x80
+}
+
+// isIdentPart reports whether c continues an identifier. Oracle names may
+// hold $ and #, SQL Server names @ and #.
+func isIdentPart(c byte) bool { return isIdentStart(c) || isDigit(c) || c == '$' }
@@ -0,0 +1,298 @@
+package sqlliteral
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mark replaces a literal with its kind, to show what was found where.
+func mark(lit Literal) string { return "<" + lit.Kind.String() + ">" }
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		// Captured AR Server statements.
+		{
+			name: "national string",
+			sql:  "SELECT T4381.C1 FROM T4381 WHERE (T4381.C1 = N'000000000003816')",
+			want: "SELECT T4381.C1 FROM T4381 WHERE (T4381.C1 = <string>)",
+		},
+		{
+			name: "plain string",
+			sql:  "SELECT T2115.C1 FROM T2115 WHERE (T2115.C1 = 'INC000000000101')",
+			want: "SELECT T2115.C1 FROM T2115 WHERE (T2115.C1 = <string>)",
+		},
+		{
+			name: "quoted identifiers and binds are structure",
+			sql:  `SELECT "EN1" AS "C0","EN2" AS "C1" FROM "T00045" WHERE ("C536870913" = ? AND "C7" = 0) ORDER BY "C6" DESC`,
+			want: `SELECT "EN1" AS "C0","EN2" AS "C1" FROM "T00045" WHERE ("C536870913" = ? AND "C7" = <number>) ORDER BY "C6" DESC`,
+		},
+		{
+			name: "update with personal data",
+			sql:  "UPDATE T1182 SET C536870914 = N'Jane O''Brien', C536870915 = 'jane.obrien@example.com', C6 = 1706954400 WHERE C1 = 'INC000000000042'",
+			want: "UPDATE T1182 SET C536870914 = <string>, C536870915 = <string>, C6 = <number> WHERE C1 = <string>",
+		},
+		{
+			name: "numeric list",
+			sql:  "SELECT C1 FROM T100 WHERE C7 IN (0,1, 2 ,-3, 4.5, .5, 1e10, 2.5E-3)",
+			want: "SELECT C1 FROM T100 WHERE C7 IN (<number>,<number>, <number> ,-<number>, <number>, <number>, <number>, <number>)",
+		},
+		{
+			name: "hex constants",
+			sql:  "SELECT C1 FROM T100 WHERE C9 = 0x1F2e OR C10 = X'00ff' OR C11 = x'AB' OR C12 = B'0101'",
+			want: "SELECT C1 FROM T100 WHERE C9 = <hex> OR C10 = <hex> OR C11 = <hex> OR C12 = <hex>",
+		},
+		{
+			name: "digits inside names",
+			sql:  "SELECT T00001.C536870913, H1182.C3, 2fa_codes.x FROM T00001, H1182, 2fa_codes",
+			want: "SELECT T00001.C536870913, H1182.C3, 2fa_codes.x FROM T00001, H1182, 2fa_codes",
+		},
+		{
+			name: "oracle names with dollar and hash",
+			sql:  "SELECT SID FROM V$SESSION WHERE USERNAME = 'ARADMIN' AND C#1 = 7",
+			want: "SELECT SID FROM V$SESSION WHERE USERNAME = <string> AND C#1 = <number>",
+		},
+		{
+			name: "positional binds",
+			sql:  "SELECT C1 FROM T1 WHERE C2 = :1 AND C3 = $2 AND C4 = :name AND C5 = @p1",
+			want: "SELECT C1 FROM T1 WHERE C2 = :1 AND C3 = $2 AND C4 = :name AND C5 = @p1",
+		},
+		{
+			name: "date functions",
+			sql:  "SELECT C1 FROM T1 WHERE C3 > TO_DATE('2026-02-03 10:00:00', 'YYYY-MM-DD HH24:MI:SS')",
+			want: "SELECT C1 FROM T1 WHERE C3 > TO_DATE(<string>, <string>)",
+		},
+		{
+			name: "like pattern",
+			sql:  "SELECT C1 FROM T45 WHERE C8 LIKE '%printer on floor 3%' ESCAPE '\\'",
+			want: "SELECT C1 FROM T45 WHERE C8 LIKE <string> ESCAPE <string>",
+		},
+		{
+			name: "block comment kept",
+			sql:  "/* Mon Nov 24 2025 14:46:58.5090 */ SELECT C1 FROM T1 WHERE C2 = 'x'",
+			want: "/* Mon Nov 24 2025 14:46:58.5090 */ SELECT C1 FROM T1 WHERE C2 = <string>",
+		},
+		{
+			name: "line comment kept",
+			sql:  "SELECT C1 FROM T1 -- don't touch 42\nWHERE C2 = 42",
+			want: "SELECT C1 FROM T1 -- don't touch 42\nWHERE C2 = <number>",
+		},
+		{
+			name: "statement without literals",
+			sql:  "OK",
+			want: "OK",
+		},
+		{
+			name: "empty",
+			sql:  "",
+			want: "",
+		},
+
+		// Pathological quoting.
+		{
+			name: "only escaped quotes",
+			sql:  "SELECT C1 FROM T1 WHERE C2 = ''''",
+			want: "SELECT C1 FROM T1 WHERE C2 = <string>",
+		},
+		{
+			name: "empty strings",
+			sql:  "SELECT C1 FROM T1 WHERE C2 = '' AND C3 = N''",
+			want: "SELECT C1 FROM T1 WHERE C2 = <string> AND C3 = <string>",
+		},
+		{
+			name: "quote inside quoted identifier",
+			sql:  `SELECT "it's" FROM "T""1" WHERE "a'b" = 'c'`,
+			want: `SELECT "it's" FROM "T""1" WHERE "a'b" = <string>`,
+		},
+		{
+			name: "bracketed identifiers",
+			sql:  "SELECT [C1], [it's]]x] FROM [dbo].[T1] WHERE [C2] = N'y'",
+			want: "SELECT [C1], [it's]]x] FROM [dbo].[T1] WHERE [C2] = <string>",
+		},
+		{
+			name: "backticks",
+			sql:  "SELECT `it's` FROM `T1` WHERE C2 = 5",
+			want: "SELECT `it's` FROM `T1` WHERE C2 = <number>",
+		},
+		{
+			name: "comment markers inside strings",
+			sql:  "SELECT C1 FROM T1 WHERE C2 = '-- not a comment' AND C3 = '/* nor this */' AND C4 = 1",
+			want: "SELECT C1 FROM T1 WHERE C2 = <string> AND C3 = <string> AND C4 = <number>",
+		},
+		{
+			name: "quotes inside comments",
+			sql:  "SELECT C1 /* it's */ FROM T1 WHERE C2 = 'a'",
+			want: "SELECT C1 /* it's */ FROM T1 WHERE C2 = <string>",
+		},
+		{
+			name: "backslash escapes in E strings",
+			sql:  `SELECT C1 FROM T1 WHERE C2 = E'it\'s' AND C3 = 'C:\temp\'`,
+			want: `SELECT C1 FROM T1 WHERE C2 = <string> AND C3 = <string>`,
+		},
+		{
+			name: "dollar quoting",
+			sql:  "SELECT C1 FROM T1 WHERE C2 = $$O'Brien$$ AND C3 = $tag$a $$ b$tag$",
+			want: "SELECT C1 FROM T1 WHERE C2 = <string> AND C3 = <string>",
+		},
+		{
+			name: "adjacent literals",
+			sql:  "SELECT 'a''b'||'c' FROM DUAL",
+			want: "SELECT <string>||<string> FROM DUAL",
+		},
+		{
+			name: "newlines inside string",
+			sql:  "UPDATE T1 SET C8 = 'line one\nline two' WHERE C1 = 'x'",
+			want: "UPDATE T1 SET C8 = <string> WHERE C1 = <string>",
+		},
+		{
+			name: "unterminated string runs to the end",
+			sql:  "UPDATE T1 SET C8 = 'the description was cut sho",
+			want: "UPDATE T1 SET C8 = <string>",
+		},
+		{
+			name: "unterminated quoted identifier",
+			sql:  `SELECT "C1 FROM T1 WHERE C2 = 'x'`,
+			want: `SELECT "C1 FROM T1 WHERE C2 = 'x'`,
+		},
+		{
+			name: "unterminated comment",
+			sql:  "SELECT C1 /* WHERE C2 = 'x'",
+			want: "SELECT C1 /* WHERE C2 = 'x'",
+		},
+		{
+			name: "prefix letters of names are not prefixes",
+			sql:  "SELECT C1 FROM T1 WHERE IN'x' = EN'y'",
+			want: "SELECT C1 FROM T1 WHERE IN<string> = EN<string>",
+		},
+		{
+			name: "multibyte text",
+			sql:  "UPDATE T1 SET C8 = N'Zoë Müller – 東京' WHERE C1 = 'ü'",
+			want: "UPDATE T1 SET C8 = <string> WHERE C1 = <string>",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Replace(tc.sql, mark))
+		})
+	}
+}
+
+func TestScan_Values(t *testing.T) {
+	tests := []struct {
+		sql       string
+		wantKind  Kind
+		wantValue string
+	}{
+		{"'abc'", String, "abc"},
+		{"N'O''Brien'", String, "O'Brien"},
+		{"n'x'", String, "x"},
+		{`E'a\'b\\c'`, String, `a'b\c`},
+		{"$$it's$$", String, "it's"},
+		{"$q$x$q$", String, "x"},
+		{"'unterminated", String, "unterminated"},
+		{"42", Number, "42"},
+		{"1.5e-3", Number, "1.5e-3"},
+		{"0xFF", Hex, "ff"},
+		{"X'Ff'", Hex, "ff"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.sql, func(t *testing.T) {
+			lits := Scan(tc.sql)
+			require.Len(t, lits, 1)
+			assert.Equal(t, tc.wantKind, lits[0].Kind)
+			assert.Equal(t, tc.wantValue, lits[0].Value)
+			assert.Equal(t, tc.sql, lits[0].Text)
+			assert.Equal(t, 0, lits[0].Start)
+			assert.Equal(t, len(tc.sql), lits[0].End)
+		})
+	}
+}
+
+func TestHasher(t *testing.T) {
+	hash := Hasher([]byte("tenant-a"))
+
+	got := Replace("UPDATE T1 SET C8 = N'Jane' WHERE C9 = 'Jane' AND C10 = 'John'", hash)
+	lits := Scan(got)
+	require.Len(t, lits, 3)
+	assert.Equal(t, lits[0].Text, lits[1].Text, "equal values get equal tokens, whatever their quoting")
+	assert.NotEqual(t, lits[0].Text, lits[2].Text)
+	assert.Regexp(t, `^'#[0-9a-f]{12}'$`, lits[0].Text)
+	assert.NotContains(t, got, "Jane")
+
+	assert.Equal(t, Replace("SELECT 0x1f", hash), Replace("SELECT X'1F'", hash))
+	assert.NotEqual(t, Replace("SELECT 'Jane'", hash), Replace("SELECT 'Jane'", Hasher([]byte("tenant-b"))),
+		"tokens differ between keys")
+	assert.Equal(t, got, Replace(got, func(lit Literal) string { return lit.Text }), "a token is a literal of its own")
+}
+
+func TestRemove(t *testing.T) {
+	got := Replace("SELECT C1 FROM T1 WHERE C2 IN (1, 2) AND C3 = N'x'", Remove)
+	assert.Equal(t, "SELECT C1 FROM T1 WHERE C2 IN ("+Placeholder+", "+Placeholder+") AND C3 = "+Placeholder, got)
+}
+
+// assertStructureKept checks that replacing the literals of sql keeps the
+// text between them byte for byte and yields as many literals again.
+func assertStructureKept(t *testing.T, sql string) {
+	t.Helper()
+	lits := Scan(sql)
+	last := 0
+	for _, lit := range lits {
+		require.GreaterOrEqual(t, lit.Start, last, "literals are ordered and do not overlap: %q", sql)
+		require.Greater(t, lit.End, lit.Start)
+		require.Equal(t, sql[lit.Start:lit.End], lit.Text)
+		last = lit.End
+	}
+	require.Equal(t, sql, Replace(sql, func(lit Literal) string { return lit.Text }))
+
+	replaced := Replace(sql, Remove)
+	rest := strings.Split(replaced, Placeholder)
+	require.Len(t, rest, len(lits)+1, "%q", sql)
+	last = 0
+	for i, lit := range lits {
+		require.Equal(t, sql[last:lit.Start], rest[i], "%q", sql)
+		last = lit.End
+	}
+	require.Equal(t, sql[last:], rest[len(lits)])
+}
+
+func TestReplace_CapturedLogs(t *testing.T) {
+	for _, name := range []string{"ar25_sample.log", "ar9_legacy_sample.log", "arsql_sample.log", "combined_sample.log"} {
+		f, err := os.Open(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 1<<20), 1<<20)
+		lines := 0
+		for sc.Scan() {
+			if line := sc.Text(); strings.Contains(line, "SQL") || strings.Contains(line, "SELECT") {
+				assertStructureKept(t, line)
+				lines++
+			}
+		}
+		require.NoError(t, sc.Err())
+		f.Close()
+		assert.Positive(t, lines, name)
+	}
+}
+
+func FuzzReplace(f *testing.F) {
+	for _, seed := range []string{
+		"SELECT C1 FROM T1 WHERE C2 = N'a''b' AND C3 IN (1,2.5,0x1F)",
+		`SELECT "it's" FROM [a]]b] WHERE c = E'\'' -- '`,
+		"SELECT $$x$$, $t$y$t$, :1, $2 /* ' */",
+		"'", "N'", "$", "0x", "1e", ".5", "[", "\"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, sql string) {
+		assertStructureKept(t, sql)
+	})
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// compactSQL collapses the whitespace of a query for comparison.
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func TestQueryAggregateGroups_Page(t *testing.T) {
+	ctx := context.Background()
+	groups := [][]any{
+		{"HPD:Help Desk", int64(40), int64(4000), 100.0, int64(1), int64(900), int64(4), 0.1, 12, 250.0, int64(40), 100.0, 250.0},
+		{"CHG:Change", int64(10), int64(500), 50.0, int64(2), int64(80), int64(0), 0.0, 3, 75.0, int64(10), 50.0, 75.0},
+	}
+	// The grand total over all 250 groups of the section, of which 31
+	// match the name filter.
+	total := []any{int64(9000), int64(450000), int64(0), int64(12000), int64(90), uint64(700), uint64(31), 180.0, int64(8800), 51.0, 185.0}
+
+	for sort, column := range map[domain.AggregateSort]string{
+		domain.AggregateSortCount:     "cnt",
+		domain.AggregateSortTotalMS:   "total_ms",
+		domain.AggregateSortAvgMS:     "avg_ms",
+		domain.AggregateSortErrorRate: "error_rate",
+	} {
+		t.Run(string(sort), func(t *testing.T) {
+			conn := &fakeConn{rows: groups, row: total}
+			page := &domain.AggregatePage{Limit: 2, Offset: 40, SortBy: sort, SortOrder: "asc", Name: "hpd"}
+			section, err := (&ClickHouseClient{conn: conn}).queryAggregateGroups(ctx, testTenantID, testJobID, "API", "form", page)
+			require.NoError(t, err)
+
+			query := compactSQL(conn.lastQuery)
+			assert.Contains(t, query, "AND form != '' AND positionCaseInsensitiveUTF8(form, @name) > 0 GROUP BY name")
+			assert.True(t, strings.HasSuffix(query, "ORDER BY "+column+" ASC, name ASC LIMIT 2 OFFSET 40"), query)
+			assert.Contains(t, conn.lastArgs, clickhouse.Named("name", "hpd"))
+
+			require.Len(t, section.Groups, 2)
+			assert.Equal(t, 31, section.TotalGroups)
+			assert.Equal(t, &domain.AggregateGroup{
+				Name: "Total", Count: 9000, TotalMS: 450000, AvgMS: 50, MinMS: 0, MaxMS: 12000,
+				ErrorCount: 90, ErrorRate: 0.01, UniqueTraces: 700,
+				P95MS: 180, TimedCount: 8800, AvgTimedMS: 51, P95TimedMS: 185,
+			}, section.GrandTotal, "the grand total covers every group, not the page")
+		})
+	}
+
+	t.Run("descending without a name filter", func(t *testing.T) {
+		conn := &fakeConn{rows: groups, row: total}
+		page := &domain.AggregatePage{Limit: 50, SortBy: domain.AggregateSortCount, SortOrder: "desc"}
+		_, err := (&ClickHouseClient{conn: conn}).queryAggregateGroups(ctx, testTenantID, testJobID, "SQL", "sql_table", page)
+		require.NoError(t, err)
+		query := compactSQL(conn.lastQuery)
+		assert.NotContains(t, query, "positionCaseInsensitiveUTF8")
+		assert.True(t, strings.HasSuffix(query, "ORDER BY cnt DESC, name ASC LIMIT 50 OFFSET 0"), query)
+	})
+
+	t.Run("page past the last group", func(t *testing.T) {
+		conn := &fakeConn{row: total}
+		page := &domain.AggregatePage{Limit: 50, Offset: 500, SortBy: domain.AggregateSortTotalMS, SortOrder: "desc"}
+		section, err := (&ClickHouseClient{conn: conn}).queryAggregateGroups(ctx, testTenantID, testJobID, "API", "form", page)
+		require.NoError(t, err)
+		assert.Empty(t, section.Groups)
+		assert.NotNil(t, section.Groups, "an empty page is an empty list")
+		assert.Equal(t, int64(9000), section.GrandTotal.Count)
+	})
+
+	t.Run("whole section", func(t *testing.T) {
+		conn := &fakeConn{rows: groups, row: []any{250.0, int64(50), 100.0, 250.0}}
+		section, err := (&ClickHouseClient{conn: conn}).queryAggregateGroups(ctx, testTenantID, testJobID, "API", "form", nil)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(compactSQL(conn.lastQuery), "GROUP BY name ORDER BY total_ms DESC"))
+		assert.Zero(t, section.TotalGroups)
+		assert.Equal(t, int64(50), section.GrandTotal.Count, "summed over the groups")
+	})
+
+	t.Run("invalid sort field", func(t *testing.T) {
+		conn := &fakeConn{}
+		_, err := (&ClickHouseClient{conn: conn}).GetAggregatesPage(ctx, testTenantID, testJobID,
+			domain.AggregatePage{Limit: 50, SortBy: "name; DROP TABLE log_entries", SortOrder: "desc"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid aggregate sort")
+		assert.Zero(t, conn.queries)
+	})
+}
@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// azureAPIVersion is the Blob service REST version requests and SAS
+	// tokens are signed for.
+	azureAPIVersion = "2020-12-06"
+
+	// azureSingleUploadMax is the largest object uploaded with one Put Blob
+	// request; larger or unsized objects are uploaded in blocks.
+	azureSingleUploadMax = 256 << 20
+
+	// azureBlockSize is the size of each block of a block upload.
+	azureBlockSize = 8 << 20
+)
+
+// AzureBlobClient stores objects in an Azure Blob Storage container. It
+// uses the Blob service REST API directly, authenticated with the storage
+// account's shared key, and pre-signs downloads as service SAS URLs.
+type AzureBlobClient struct {
+	client    *http.Client
+	endpoint  *url.URL // https://{account}.blob.core.windows.net, or an emulator URL
+	account   string
+	key       []byte
+	container string
+}
+
+// NewAzureBlobClient creates a client for container in the given storage
+// account. endpoint defaults to the public endpoint of the account; set it
+// for Azurite or sovereign clouds (e.g. "http://127.0.0.1:10000/devstoreaccount1").
+// The container must already exist.
+func NewAzureBlobClient(endpoint, account, accountKey, container string) (*AzureBlobClient, error) {
+	if account == "" || accountKey == "" {
+		return nil, fmt.Errorf("azure: storage account and key are required")
+	}
+	if container == "" {
+		return nil, fmt.Errorf("azure: container name is required")
+	}
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("azure: account key is not valid base64: %w", err)
+	}
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("azure: invalid endpoint %q: %w", endpoint, err)
+	}
+
+	return &AzureBlobClient{
+		client:    &http.Client{},
+		endpoint:  u,
+		account:   account,
+		key:       key,
+		container: container,
+	}, nil
+}
+
+// blobURL returns the URL of the blob at key with the given query.
+func (a *AzureBlobClient) blobURL(key string, query url.Values) *url.URL {
+	u := *a.endpoint
+	u.Path = u.Path + "/" + a.container + "/" + key
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// Upload stores an object. Objects of known size up to azureSingleUploadMax
+// are sent in one request; others are staged as blocks and committed.
+func (a *AzureBlobClient) Upload(ctx context.Context, key string, reader io.Reader, size int64) error {
+	if size >= 0 && size <= azureSingleUploadMax {
+		req, err := a.newRequest(ctx, http.MethodPut, a.blobURL(key, nil), reader, size)
+		if err != nil {
+			return fmt.Errorf("azure: upload %q: %w", key, err)
+		}
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		if err := a.do(req, http.StatusCreated); err != nil {
+			return fmt.Errorf("azure: upload %q: %w", key, err)
+		}
+		return nil
+	}
+
+	var blockIDs []string
+	buf := make([]byte, azureBlockSize)
+	for {
+		n, readErr := io.ReadFull(reader, buf)
+		if n > 0 {
+			id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%010d", len(blockIDs))))
+			q := url.Values{"comp": {"block"}, "blockid": {id}}
+			req, err := a.newRequest(ctx, http.MethodPut, a.blobURL(key, q), bytes.NewReader(buf[:n]), int64(n))
+			if err != nil {
+				return fmt.Errorf("azure: upload %q: %w", key, err)
+			}
+			if err := a.do(req, http.StatusCreated); err != nil {
+				return fmt.Errorf("azure: upload %q block %d: %w", key, len(blockIDs), err)
+			}
+			blockIDs = append(blockIDs, id)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("azure: upload %q: %w", key, readErr)
+		}
+	}
+
+	var list bytes.Buffer
+	list.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+	for _, id := range blockIDs {
+		list.WriteString("<Latest>" + id + "</Latest>")
+	}
+	list.WriteString("</BlockList>")
+	req, err := a.newRequest(ctx, http.MethodPut, a.blobURL(key, url.Values{"comp": {"blocklist"}}), bytes.NewReader(list.Bytes()), int64(list.Len()))
+	if err != nil {
+		return fmt.Errorf("azure: upload %q: %w", key, err)
+	}
+	if err := a.do(req, http.StatusCreated); err != nil {
+		return fmt.Errorf("azure: commit %q: %w", key, err)
+	}
+	return nil
+}
+
+// Download returns the content of the object at key. The caller must close
+// the reader.
+func (a *AzureBlobClient) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := a.newRequest(ctx, http.MethodGet, a.blobURL(key, nil), nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("azure: download %q: %w", key, err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("azure: download %q: %w", key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("azure: download %q: %w", key, azureError(resp))
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object at key. Deleting a missing object is not an
+// error, as with S3.
+func (a *AzureBlobClient) Delete(ctx context.Context, key string) error {
+	req, err := a.newRequest(ctx, http.MethodDelete, a.blobURL(key, nil), nil, 0)
+	if err != nil {
+		return fmt.Errorf("azure: delete %q: %w", key, err)
+	}
+	if err := a.do(req, http.StatusAccepted, http.StatusNotFound); err != nil {
+		return fmt.Errorf("azure: delete %q: %w", key, err)
+	}
+	return nil
+}
+
+// Exists reports whether an object is stored at key.
+func (a *AzureBlobClient) Exists(ctx context.Context, key string) (bool, error) {
+	req, err := a.newRequest(ctx, http.MethodHead, a.blobURL(key, nil), nil, 0)
+	if err != nil {
+		return false, fmt.Errorf("azure: head %q: %w", key, err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("azure: head %q: %w", key, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("azure: head %q: unexpected status %s", key, resp.Status)
+	}
+}
+
+// PresignGetURL returns a read-only service SAS URL for the object at key.
+// The URL is signed locally; no request is made to Azure.
+func (a *AzureBlobClient) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	expires := time.Now().UTC().Add(expiry).Format("2006-01-02T15:04:05Z")
+	canonical := "/blob/" + a.account + "/" + a.container + "/" + key
+	stringToSign := strings.Join([]string{
+		"r",       // signed permissions
+		"",        // signed start
+		expires,   // signed expiry
+		canonical, // canonicalized resource
+		"",        // signed identifier
+		"",        // signed IP
+		"",        // signed protocol
+		azureAPIVersion,
+		"b",                // signed resource: blob
+		"",                 // snapshot time
+		"",                 // encryption scope
+		"", "", "", "", "", // response header overrides
+	}, "\n")
+
+	q := url.Values{
+		"sv":  {azureAPIVersion},
+		"sr":  {"b"},
+		"sp":  {"r"},
+		"se":  {expires},
+		"sig": {a.sign(stringToSign)},
+	}
+	return a.blobURL(key, q).String(), nil
+}
+
+// newRequest builds a request signed with the shared key.
+func (a *AzureBlobClient) newRequest(ctx context.Context, method string, u *url.URL, body io.Reader, size int64) (*http.Request, error) {
+	if body != nil && size == 0 {
+		// An empty body of unknown length would be sent chunked.
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && body != http.NoBody {
+		req.ContentLength = size
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("Authorization", "SharedKey "+a.account+":"+a.sign(a.stringToSign(req)))
+	return req, nil
+}
+
+// stringToSign builds the Shared Key string to sign of a request.
+func (a *AzureBlobClient) stringToSign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	h := req.Header
+	parts := []string{
+		req.Method,
+		h.Get("Content-Encoding"),
+		h.Get("Content-Language"),
+		contentLength,
+		h.Get("Content-MD5"),
+		h.Get("Content-Type"),
+		"", // Date; x-ms-date is used instead
+		h.Get("If-Modified-Since"),
+		h.Get("If-Match"),
+		h.Get("If-None-Match"),
+		h.Get("If-Unmodified-Since"),
+		h.Get("Range"),
+	}
+
+	var msHeaders []string
+	for name := range h {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			msHeaders = append(msHeaders, lower)
+		}
+	}
+	sort.Strings(msHeaders)
+	var canonical strings.Builder
+	for _, name := range msHeaders {
+		canonical.WriteString(name + ":" + strings.TrimSpace(h.Get(name)) + "\n")
+	}
+
+	canonical.WriteString("/" + a.account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		canonical.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	return strings.Join(parts, "\n") + "\n" + canonical.String()
+}
+
+func (a *AzureBlobClient) sign(s string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// do sends req and fails unless the response status is one of ok.
+func (a *AzureBlobClient) do(req *http.Request, ok ...int) error {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, status := range ok {
+		if resp.StatusCode == status {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			return nil
+		}
+	}
+	return azureError(resp)
+}
+
+// azureError describes a failed response using the x-ms-error-code header.
+func azureError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if code := resp.Header.Get("x-ms-error-code"); code != "" {
+		return fmt.Errorf("%s: %s", resp.Status, code)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
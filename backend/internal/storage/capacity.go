@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// GetQueueLoad returns the load the threads of every queue carried over a
+// job's capture. Only API calls count: the SQL and filter work of a call
+// runs on its thread within the call.
+func (c *ClickHouseClient) GetQueueLoad(ctx context.Context, tenantID, jobID string) ([]domain.QueueLoad, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	query := `
+		SELECT
+			queue,
+			toInt64(uniqExactMerge(threads)) AS observed_threads,
+			toInt64(sum(busy_ms)) AS busy_ms,
+			argMax(minute, busy_ms) AS peak_minute,
+			toInt64(max(busy_ms)) AS peak_minute_busy_ms,
+			(
+				SELECT toInt64(dateDiff('millisecond', min(timestamp), max(timestamp)))
+				FROM log_entries
+				WHERE tenant_id = @tenantID AND job_id = @jobID
+			) AS span_ms
+		FROM (
+			SELECT
+				queue,
+				toStartOfMinute(timestamp) AS minute,
+				sum(duration_ms) AS busy_ms,
+				uniqExactState(thread_id) AS threads
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID
+				AND log_type = 'API' AND queue != '' AND thread_id != ''
+			GROUP BY queue, minute
+		)
+		GROUP BY queue
+		ORDER BY queue
+	`
+	if c.hasRollup(ctx, tenantID, jobID) {
+		// The rollup holds one row per queue and minute of API calls.
+		query = `
+			SELECT
+				queue,
+				toInt64(uniqExactMerge(threads)) AS observed_threads,
+				toInt64(sum(busy_ms)) AS busy_ms,
+				argMax(minute, busy_ms) AS peak_minute,
+				toInt64(max(busy_ms)) AS peak_minute_busy_ms,
+				(
+					SELECT toInt64(dateDiff('millisecond', min(first_ts), max(last_ts)))
+					FROM ` + rollupTable + `
+					WHERE tenant_id = @tenantID AND job_id = @jobID
+				) AS span_ms
+			FROM ` + rollupTable + `
+			WHERE tenant_id = @tenantID AND job_id = @jobID
+				AND log_type = 'API' AND queue != '' AND threaded_entries > 0
+			GROUP BY queue
+			ORDER BY queue
+		`
+	}
+	rows, err := c.conn.Query(ctx, query,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: queue load: %w", err)
+	}
+	defer rows.Close()
+
+	var loads []domain.QueueLoad
+	for rows.Next() {
+		var (
+			l        domain.QueueLoad
+			observed int64
+		)
+		if err := rows.Scan(&l.Queue, &observed, &l.BusyMS, &l.PeakMinute, &l.PeakMinuteBusyMS, &l.SpanMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: queue load scan: %w", err)
+		}
+		l.ObservedThreads = int(observed)
+		loads = append(loads, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: queue load rows: %w", err)
+	}
+	return loads, nil
+}
+
+// ThreadCapacityReport compares the load of every queue with the threads
+// configured for it. Queues configured but idle are reported too. A queue
+// the configured counts miss, or every queue when there are none, is
+// compared with the threads observed on it instead and flagged Estimated.
+func ThreadCapacityReport(loads []domain.QueueLoad, configured map[string]int) *domain.ThreadCapacity {
+	report := &domain.ThreadCapacity{Queues: []domain.QueueCapacity{}, Estimated: len(configured) == 0}
+
+	// Queues are matched without regard to case: the preamble and the log
+	// lines need not spell them alike.
+	configuredQueues := make(map[string]string, len(configured))
+	for queue := range configured {
+		configuredQueues[strings.ToLower(queue)] = queue
+	}
+
+	for _, l := range loads {
+		q := domain.QueueCapacity{Queue: l.Queue, ObservedThreads: l.ObservedThreads}
+		if name, ok := configuredQueues[strings.ToLower(l.Queue)]; ok {
+			q.ConfiguredThreads = configured[name]
+			delete(configuredQueues, strings.ToLower(l.Queue))
+		} else {
+			q.ConfiguredThreads = l.ObservedThreads
+			q.Estimated = true
+		}
+		q.BusyPct = capacityPct(l.BusyMS, q.ConfiguredThreads, l.SpanMS)
+		if !l.PeakMinute.IsZero() {
+			q.PeakMinute = domain.TimestampPtr(&l.PeakMinute)
+			// A capture shorter than a minute only fills part of it.
+			q.PeakMinuteBusyPct = capacityPct(l.PeakMinuteBusyMS, q.ConfiguredThreads, min(l.SpanMS, time.Minute.Milliseconds()))
+		}
+		q.Verdict = capacityVerdict(q.PeakMinuteBusyPct)
+		report.Queues = append(report.Queues, q)
+	}
+	for _, name := range configuredQueues {
+		report.Queues = append(report.Queues, domain.QueueCapacity{
+			Queue:             name,
+			ConfiguredThreads: configured[name],
+			Verdict:           capacityVerdict(0),
+		})
+	}
+
+	sort.Slice(report.Queues, func(i, j int) bool { return report.Queues[i].Queue < report.Queues[j].Queue })
+	return report
+}
+
+// capacityPct is busyMS as a percentage of what threads can carry over
+// windowMS, at most 100 and rounded to one decimal.
+func capacityPct(busyMS int64, threads int, windowMS int64) float64 {
+	if threads <= 0 || windowMS <= 0 {
+		return 0
+	}
+	pct := math.Min(float64(busyMS)/(float64(threads)*float64(windowMS))*100, 100)
+	return math.Round(pct*10) / 10
+}
+
+// capacityVerdict rates a queue's peak-minute utilization with the
+// breakpoints and status thresholds of the health score's thread
+// saturation factor.
+func capacityVerdict(busyPct float64) domain.CapacityVerdict {
+	switch scoreSeverity(scoreThreadSaturation(busyPct)) {
+	case "green":
+		return domain.CapacityHealthy
+	case "red":
+		return domain.CapacitySaturated
+	default:
+		return domain.CapacityNearCapacity
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestThreadCapacityReport(t *testing.T) {
+	peak := time.Date(2025, 11, 24, 14, 47, 0, 0, time.UTC)
+	loads := []domain.QueueLoad{
+		// 4 Fast threads over 10 minutes carry 2,400 thread-seconds.
+		{Queue: "fast", ObservedThreads: 3, BusyMS: 1_200_000, SpanMS: 600_000, PeakMinute: peak, PeakMinuteBusyMS: 228_000},
+		{Queue: "Prv:390680", ObservedThreads: 2, BusyMS: 60_000, SpanMS: 600_000, PeakMinute: peak, PeakMinuteBusyMS: 48_000},
+	}
+	report := ThreadCapacityReport(loads, map[string]int{"Fast": 4, "List": 20})
+
+	assert.False(t, report.Estimated)
+	require.Len(t, report.Queues, 3)
+
+	fast := report.Queues[2]
+	assert.Equal(t, "fast", fast.Queue, "the queue keeps the name the log lines use")
+	assert.Equal(t, 4, fast.ConfiguredThreads)
+	assert.Equal(t, 3, fast.ObservedThreads)
+	assert.False(t, fast.Estimated)
+	assert.Equal(t, 50.0, fast.BusyPct)
+	assert.Equal(t, 95.0, fast.PeakMinuteBusyPct)
+	require.NotNil(t, fast.PeakMinute)
+	assert.True(t, fast.PeakMinute.Equal(peak))
+	assert.Equal(t, domain.CapacitySaturated, fast.Verdict)
+
+	list := report.Queues[0]
+	assert.Equal(t, "List", list.Queue, "configured idle queues are reported")
+	assert.Equal(t, 20, list.ConfiguredThreads)
+	assert.Zero(t, list.ObservedThreads)
+	assert.Nil(t, list.PeakMinute)
+	assert.Equal(t, domain.CapacityHealthy, list.Verdict)
+
+	prv := report.Queues[1]
+	assert.Equal(t, "Prv:390680", prv.Queue)
+	assert.True(t, prv.Estimated, "a queue missing from the configured counts is estimated")
+	assert.Equal(t, 2, prv.ConfiguredThreads)
+	assert.Equal(t, 5.0, prv.BusyPct)
+	assert.Equal(t, 40.0, prv.PeakMinuteBusyPct)
+}
+
+func TestThreadCapacityReport_EstimatesWithoutConfiguredCounts(t *testing.T) {
+	report := ThreadCapacityReport([]domain.QueueLoad{
+		{Queue: "Fast", ObservedThreads: 2, BusyMS: 9_000, SpanMS: 10_000, PeakMinute: time.Now(), PeakMinuteBusyMS: 9_000},
+	}, nil)
+
+	assert.True(t, report.Estimated)
+	require.Len(t, report.Queues, 1)
+	q := report.Queues[0]
+	assert.True(t, q.Estimated)
+	assert.Equal(t, 2, q.ConfiguredThreads)
+	assert.Equal(t, 45.0, q.BusyPct)
+	// A capture shorter than a minute is measured over its own span.
+	assert.Equal(t, 45.0, q.PeakMinuteBusyPct)
+
+	empty := ThreadCapacityReport(nil, nil)
+	assert.True(t, empty.Estimated)
+	assert.NotNil(t, empty.Queues)
+	assert.Empty(t, empty.Queues)
+}
+
+func TestCapacityPct(t *testing.T) {
+	assert.Equal(t, 25.0, capacityPct(15_000, 1, 60_000))
+	assert.Equal(t, 33.3, capacityPct(20_000, 1, 60_000))
+	assert.Equal(t, 100.0, capacityPct(200_000, 2, 60_000), "overlapping calls are capped")
+	assert.Zero(t, capacityPct(1_000, 0, 60_000))
+	assert.Zero(t, capacityPct(1_000, 2, 0))
+}
+
+// TestCapacityVerdict pins the verdicts to the thread saturation factor of
+// the default health profile.
+func TestCapacityVerdict(t *testing.T) {
+	tests := []struct {
+		busyPct float64
+		want    domain.CapacityVerdict
+	}{
+		{0, domain.CapacityHealthy},
+		{49.9, domain.CapacityHealthy},
+		{50, domain.CapacityNearCapacity},
+		{84.9, domain.CapacityNearCapacity},
+		{85, domain.CapacitySaturated},
+		{100, domain.CapacitySaturated},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, capacityVerdict(tt.busyPct), "busy %.1f%%", tt.busyPct)
+		assert.Equal(t, tt.want == domain.CapacityHealthy, scoreThreadSaturation(tt.busyPct) > DefaultHealthProfile().GreenAbove)
+	}
+}
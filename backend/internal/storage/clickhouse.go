@@ -3,10 +3,12 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +25,9 @@ type JobTimeRange struct {
 	End   time.Time
 }
 
+// ErrNoJobEntries is returned by GetJobTimeRange for a job without entries.
+var ErrNoJobEntries = errors.New("no entries found")
+
 // SearchQuery defines the parameters for a paginated log search.
 type SearchQuery struct {
 	Query       string     `json:"query"`
@@ -38,6 +43,19 @@ type SearchQuery struct {
 	Page        int        `json:"page"`
 	PageSize    int        `json:"page_size"`
 	ExportMode  bool       `json:"-"` // bypass page_size cap (export only)
+
+	// Computed are the computed columns selected for each entry; SortBy
+	// may name one of them.
+	Computed []search.CompiledColumn `json:"-"`
+
+	// IncludeNoise also matches the entries ingestion filter rules flagged
+	// as noise.
+	IncludeNoise bool `json:"include_noise,omitempty"`
+
+	// SecondarySortBy orders the entries that tie on SortBy, before the
+	// tiebreakers of EffectiveSort.
+	SecondarySortBy    string `json:"secondary_sort_by,omitempty"`
+	SecondarySortOrder string `json:"secondary_sort_order,omitempty"`
 }
 
 // SearchResult holds the results from a paginated log search.
@@ -45,6 +63,10 @@ type SearchResult struct {
 	Entries    []domain.LogEntry `json:"entries"`
 	TotalCount int64             `json:"total_count"`
 	TookMS     int               `json:"took_ms"`
+
+	// Computed holds the values of the query's computed columns by name,
+	// one map per entry, when it has any.
+	Computed []map[string]any `json:"computed,omitempty"`
 }
 
 // FacetValue holds a single value and its count for faceted search results.
@@ -53,9 +75,27 @@ type FacetValue struct {
 	Count int64  `json:"count"`
 }
 
-// ClickHouseClient wraps a ClickHouse connection pool.
+// ClickHouseClient wraps a ClickHouse connection pool. Statements run with
+// a context from WithQueryID carry that request's query ID, and those run
+// with a context from WithTenant go to the cluster the tenant is routed to.
 type ClickHouseClient struct {
-	conn driver.Conn
+	conn   driver.Conn
+	router *clusterRouter
+
+	// rollups tracks the jobs whose minute rollup reads can use. Nil reads
+	// every job from log_entries.
+	rollups *rollupIndex
+
+	// results caches the reads of complete jobs that opt in with
+	// cacheReads. Nil disables it.
+	results *resultCache
+
+	// replica is the default cluster with its read replica, which the
+	// read-only analytics query. Nil without one.
+	replica *replicaConn
+
+	// searchLimits bound the statements of interactive searches.
+	searchLimits SearchLimits
 }
 
 // NewClickHouseClient creates a new ClickHouse client from the given DSN.
@@ -76,7 +116,8 @@ func NewClickHouseClient(ctx context.Context, dsn string) (*ClickHouseClient, er
 		return nil, fmt.Errorf("clickhouse: ping: %w", err)
 	}
 
-	return &ClickHouseClient{conn: conn}, nil
+	router := newClusterRouter(conn)
+	return &ClickHouseClient{conn: taggedConn{router}, router: router, rollups: newRollupIndex()}, nil
 }
 
 // Close releases the underlying connection pool.
@@ -99,12 +140,35 @@ func escapeLikePattern(s string) string {
 	return s
 }
 
+type insertTokenKey struct{}
+
+// WithInsertToken returns a context whose BatchInsertEntries sends its batch
+// with token as the insert deduplication token: a batch sent again with the
+// same token, such as one a crashed worker was unsure it stored, is
+// dropped by ClickHouse instead of stored twice.
+func WithInsertToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, insertTokenKey{}, token)
+}
+
+// InsertToken returns the insert deduplication token of ctx, empty
+// without one.
+func InsertToken(ctx context.Context) string {
+	token, _ := ctx.Value(insertTokenKey{}).(string)
+	return token
+}
+
 // BatchInsertEntries inserts a batch of log entries into the log_entries table.
 // All entries are inserted within a single batch for optimal throughput.
 func (c *ClickHouseClient) BatchInsertEntries(ctx context.Context, entries []domain.LogEntry) error {
 	if len(entries) == 0 {
 		return nil
 	}
+	if token := InsertToken(ctx); token != "" {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+			"insert_deduplication_token":                         token,
+			"deduplicate_blocks_in_dependent_materialized_views": 1,
+		}))
+	}
 
 	batch, err := c.conn.PrepareBatch(ctx, `
 		INSERT INTO log_entries (
@@ -113,11 +177,12 @@ func (c *ClickHouseClient) BatchInsertEntries(ctx context.Context, entries []dom
 			trace_id, rpc_id, thread_id,
 			queue, user,
 			duration_ms, queue_time_ms, success,
-			api_code, form,
+			api_code, form, client, client_ip,
 			sql_table, sql_statement,
 			filter_name, filter_level, operation, request_id,
 			esc_name, esc_pool, scheduled_time, delay_ms, error_encountered,
-			raw_text, error_message
+			raw_text, error_message, retention_class, is_noise, sample_weight,
+			raw_text_truncated
 		)
 	`)
 	if err != nil {
@@ -130,20 +195,31 @@ func (c *ClickHouseClient) BatchInsertEntries(ctx context.Context, entries []dom
 		// Normalise nil scheduled_time to zero value for ClickHouse.
 		scheduledTime := time.Time{}
 		if e.ScheduledTime != nil {
-			scheduledTime = *e.ScheduledTime
+			scheduledTime = e.ScheduledTime.Time
+		}
+
+		retentionClass := e.RetentionClass
+		if retentionClass == "" {
+			retentionClass = domain.RetentionStandard
+		}
+
+		weight := e.SampleWeight
+		if weight == 0 {
+			weight = 1
 		}
 
 		if err := batch.Append(
 			e.TenantID, e.JobID, e.EntryID, e.LineNumber, e.FileNumber,
-			e.Timestamp, e.IngestedAt, string(e.LogType),
+			e.Timestamp.Time, e.IngestedAt.Time, string(e.LogType),
 			e.TraceID, e.RPCID, e.ThreadID,
 			e.Queue, e.User,
 			e.DurationMS, e.QueueTimeMS, e.Success,
-			e.APICode, e.Form,
+			e.APICode, e.Form, e.Client, e.ClientIP,
 			e.SQLTable, e.SQLStatement,
 			e.FilterName, e.FilterLevel, e.Operation, e.RequestID,
 			e.EscName, e.EscPool, scheduledTime, e.DelayMS, e.ErrorEncountered,
-			e.RawText, e.ErrorMessage,
+			e.RawText, e.ErrorMessage, string(retentionClass), e.IsNoise, weight,
+			e.RawTextTruncated,
 		); err != nil {
 			return fmt.Errorf("clickhouse: append row %d: %w", i, err)
 		}
@@ -160,6 +236,8 @@ func (c *ClickHouseClient) BatchInsertEntries(ctx context.Context, entries []dom
 // a given tenant and job. topN controls how many entries to return in each
 // "top" ranking.
 func (c *ClickHouseClient) GetDashboardData(ctx context.Context, tenantID, jobID string, topN int) (*domain.DashboardData, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	if topN <= 0 {
 		topN = 25
 	}
@@ -169,33 +247,38 @@ func (c *ClickHouseClient) GetDashboardData(ctx context.Context, tenantID, jobID
 	}
 
 	// --- General statistics ---
-	if err := c.queryGeneralStats(ctx, tenantID, jobID, &dash.GeneralStats); err != nil {
+	if err := c.queryGeneralStats(ctx, tenantID, jobID, &dash.GeneralStats, &dash.Estimate); err != nil {
 		return nil, fmt.Errorf("clickhouse: general stats: %w", err)
 	}
 
 	// --- Top-N per log type ---
-	var err error
-	dash.TopAPICalls, err = c.queryTopN(ctx, tenantID, jobID, domain.LogTypeAPI, topN)
-	if err != nil {
-		return nil, fmt.Errorf("clickhouse: top api: %w", err)
-	}
-
-	dash.TopSQL, err = c.queryTopN(ctx, tenantID, jobID, domain.LogTypeSQL, topN)
-	if err != nil {
-		return nil, fmt.Errorf("clickhouse: top sql: %w", err)
-	}
-
-	dash.TopFilters, err = c.queryTopN(ctx, tenantID, jobID, domain.LogTypeFilter, topN)
-	if err != nil {
-		return nil, fmt.Errorf("clickhouse: top filters: %w", err)
-	}
-
-	dash.TopEscalations, err = c.queryTopN(ctx, tenantID, jobID, domain.LogTypeEscalation, topN)
-	if err != nil {
-		return nil, fmt.Errorf("clickhouse: top escalations: %w", err)
+	// Log types without entries are not queried and are listed in NoData.
+	tops := []struct {
+		key     string
+		logType domain.LogType
+		count   int64
+		dst     *[]domain.TopNEntry
+	}{
+		{"api", domain.LogTypeAPI, dash.GeneralStats.APICount, &dash.TopAPICalls},
+		{"sql", domain.LogTypeSQL, dash.GeneralStats.SQLCount, &dash.TopSQL},
+		{"filters", domain.LogTypeFilter, dash.GeneralStats.FilterCount, &dash.TopFilters},
+		{"escalations", domain.LogTypeEscalation, dash.GeneralStats.EscCount, &dash.TopEscalations},
+	}
+	for _, t := range tops {
+		if t.count == 0 {
+			*t.dst = []domain.TopNEntry{}
+			dash.NoData = append(dash.NoData, t.key)
+			continue
+		}
+		entries, err := c.queryTopN(ctx, tenantID, jobID, t.logType, topN)
+		if err != nil {
+			return nil, fmt.Errorf("clickhouse: top %s: %w", t.key, err)
+		}
+		*t.dst = entries
 	}
 
 	// --- Time series ---
+	var err error
 	dash.TimeSeries, err = c.queryTimeSeries(ctx, tenantID, jobID)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: time series: %w", err)
@@ -209,19 +292,22 @@ func (c *ClickHouseClient) GetDashboardData(ctx context.Context, tenantID, jobID
 	return dash, nil
 }
 
-func (c *ClickHouseClient) queryGeneralStats(ctx context.Context, tenantID, jobID string, stats *domain.GeneralStatistics) error {
+// queryGeneralStats fills stats and labels them with the estimate of the
+// job's entries: counts are the sums of the entries' sample weights.
+func (c *ClickHouseClient) queryGeneralStats(ctx context.Context, tenantID, jobID string, stats *domain.GeneralStatistics, est *domain.Estimate) error {
 	row := c.conn.QueryRow(ctx, `
 		SELECT
-			count()                                             AS total_lines,
-			countIf(log_type = 'API')                           AS api_count,
-			countIf(log_type = 'SQL')                           AS sql_count,
-			countIf(log_type = 'FLTR')                          AS filter_count,
-			countIf(log_type = 'ESCL')                          AS esc_count,
+			sum(sample_weight)                                  AS total_lines,
+			sumIf(sample_weight, log_type = 'API')              AS api_count,
+			sumIf(sample_weight, log_type = 'SQL')              AS sql_count,
+			sumIf(sample_weight, log_type = 'FLTR')             AS filter_count,
+			sumIf(sample_weight, log_type = 'ESCL')             AS esc_count,
 			uniqExact(user)                                     AS unique_users,
 			uniqExactIf(form, form != '')                       AS unique_forms,
 			uniqExactIf(sql_table, sql_table != '')             AS unique_tables,
 			min(timestamp)                                      AS log_start,
-			max(timestamp)                                      AS log_end
+			max(timestamp)                                      AS log_end,
+			max(sample_weight)                                  AS max_weight
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID
 	`,
@@ -240,6 +326,7 @@ func (c *ClickHouseClient) queryGeneralStats(ctx context.Context, tenantID, jobI
 		uniqueTables uint64
 		logStart     time.Time
 		logEnd       time.Time
+		maxWeight    uint32
 	)
 	if err := row.Scan(
 		&totalLines,
@@ -252,9 +339,11 @@ func (c *ClickHouseClient) queryGeneralStats(ctx context.Context, tenantID, jobI
 		&uniqueTables,
 		&logStart,
 		&logEnd,
+		&maxWeight,
 	); err != nil {
 		return fmt.Errorf("clickhouse: general stats scan: %w", err)
 	}
+	*est = domain.NewEstimate(maxWeight)
 	stats.TotalLines = int64(totalLines)
 	stats.APICount = int64(apiCount)
 	stats.SQLCount = int64(sqlCount)
@@ -263,11 +352,12 @@ func (c *ClickHouseClient) queryGeneralStats(ctx context.Context, tenantID, jobI
 	stats.UniqueUsers = int(uniqueUsers)
 	stats.UniqueForms = int(uniqueForms)
 	stats.UniqueTables = int(uniqueTables)
-	stats.LogStart = logStart
-	stats.LogEnd = logEnd
+	stats.LogStart = domain.NewTimestamp(logStart)
+	stats.LogEnd = domain.NewTimestamp(logEnd)
 
 	if !logStart.IsZero() && !logEnd.IsZero() {
-		stats.LogDuration = logEnd.Sub(logStart).String()
+		stats.LogDurationMS = domain.DurationMSOf(logEnd.Sub(logStart))
+		stats.LogDuration = stats.LogDurationMS.Display()
 	}
 
 	return nil
@@ -420,20 +510,37 @@ func (c *ClickHouseClient) queryTopN(ctx context.Context, tenantID, jobID string
 }
 
 func (c *ClickHouseClient) queryTimeSeries(ctx context.Context, tenantID, jobID string) ([]domain.TimeSeriesPoint, error) {
-	rows, err := c.conn.Query(ctx, `
+	query := `
 		SELECT
-			toStartOfMinute(timestamp)     AS ts,
-			countIf(log_type = 'API')      AS api_count,
-			countIf(log_type = 'SQL')      AS sql_count,
-			countIf(log_type = 'FLTR')     AS filter_count,
-			countIf(log_type = 'ESCL')     AS esc_count,
-			avg(duration_ms)               AS avg_duration_ms,
-			countIf(success = false)       AS error_count
+			toStartOfMinute(timestamp)                      AS ts,
+			sumIf(sample_weight, log_type = 'API')          AS api_count,
+			sumIf(sample_weight, log_type = 'SQL')          AS sql_count,
+			sumIf(sample_weight, log_type = 'FLTR')         AS filter_count,
+			sumIf(sample_weight, log_type = 'ESCL')         AS esc_count,
+			avgWeighted(duration_ms, sample_weight)         AS avg_duration_ms,
+			sumIf(sample_weight, success = false)           AS error_count
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID
 		GROUP BY ts
 		ORDER BY ts
-	`,
+	`
+	if c.hasRollup(ctx, tenantID, jobID) {
+		query = `
+			SELECT
+				minute                                  AS ts,
+				sumIf(entries, log_type = 'API')        AS api_count,
+				sumIf(entries, log_type = 'SQL')        AS sql_count,
+				sumIf(entries, log_type = 'FLTR')       AS filter_count,
+				sumIf(entries, log_type = 'ESCL')       AS esc_count,
+				sum(duration_ms_weighted) / sum(entries) AS avg_duration_ms,
+				sum(errors)                             AS error_count
+			FROM ` + rollupTable + `
+			WHERE tenant_id = @tenantID AND job_id = @jobID
+			GROUP BY ts
+			ORDER BY ts
+		`
+	}
+	rows, err := c.conn.Query(ctx, query,
 		clickhouse.Named("tenantID", tenantID),
 		clickhouse.Named("jobID", jobID),
 	)
@@ -478,7 +585,7 @@ func (c *ClickHouseClient) queryTimeSeries(ctx context.Context, tenantID, jobID
 func (c *ClickHouseClient) queryDistribution(ctx context.Context, tenantID, jobID string, dash *domain.DashboardData) error {
 	// Distribution by log type.
 	typeRows, err := c.conn.Query(ctx, `
-		SELECT toString(log_type) AS lt, count() AS cnt
+		SELECT toString(log_type) AS lt, sum(sample_weight) AS cnt
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID
 		GROUP BY lt
@@ -507,7 +614,7 @@ func (c *ClickHouseClient) queryDistribution(ctx context.Context, tenantID, jobI
 
 	// Distribution by queue (top 20).
 	queueRows, err := c.conn.Query(ctx, `
-		SELECT queue, count() AS cnt
+		SELECT queue, sum(sample_weight) AS cnt
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID AND queue != ''
 		GROUP BY queue
@@ -536,11 +643,45 @@ func (c *ClickHouseClient) queryDistribution(ctx context.Context, tenantID, jobI
 	}
 	dash.Distribution["by_queue"] = byQueue
 
+	// Distribution of API calls by client address (top 20).
+	ipRows, err := c.conn.Query(ctx, `
+		SELECT client_ip, sum(sample_weight) AS cnt
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = 'API' AND client_ip != ''
+		GROUP BY client_ip
+		ORDER BY cnt DESC
+		LIMIT 20
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return fmt.Errorf("clickhouse: distribution by client ip query: %w", err)
+	}
+	defer ipRows.Close()
+
+	byClientIP := make(map[string]int)
+	for ipRows.Next() {
+		var ip string
+		var cnt uint64
+		if err := ipRows.Scan(&ip, &cnt); err != nil {
+			return fmt.Errorf("clickhouse: distribution by client ip scan: %w", err)
+		}
+		byClientIP[ip] = int(cnt)
+	}
+	if err := ipRows.Err(); err != nil {
+		return fmt.Errorf("clickhouse: distribution by client ip rows: %w", err)
+	}
+	if len(byClientIP) > 0 {
+		dash.Distribution["by_client_ip"] = byClientIP
+	}
+
 	return nil
 }
 
 // GetLogEntry retrieves a single log entry by its composite key.
 func (c *ClickHouseClient) GetLogEntry(ctx context.Context, tenantID, jobID, entryID string) (*domain.LogEntry, error) {
+	ctx = replicaRead(ctx)
 	row := c.conn.QueryRow(ctx, `
 		SELECT
 			tenant_id, job_id, entry_id, line_number, file_number,
@@ -548,11 +689,11 @@ func (c *ClickHouseClient) GetLogEntry(ctx context.Context, tenantID, jobID, ent
 			trace_id, rpc_id, thread_id,
 			queue, user,
 			duration_ms, queue_time_ms, success,
-			api_code, form,
+			api_code, form, client, client_ip,
 			sql_table, sql_statement,
 			filter_name, filter_level, operation, request_id,
 			esc_name, esc_pool, scheduled_time, delay_ms, error_encountered,
-			raw_text, error_message
+			raw_text, error_message, raw_text_truncated
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID AND entry_id = @entryID
 		LIMIT 1
@@ -571,26 +712,57 @@ func (c *ClickHouseClient) GetLogEntry(ctx context.Context, tenantID, jobID, ent
 		&e.TraceID, &e.RPCID, &e.ThreadID,
 		&e.Queue, &e.User,
 		&e.DurationMS, &e.QueueTimeMS, &e.Success,
-		&e.APICode, &e.Form,
+		&e.APICode, &e.Form, &e.Client, &e.ClientIP,
 		&e.SQLTable, &e.SQLStatement,
 		&e.FilterName, &e.FilterLevel, &e.Operation, &e.RequestID,
 		&e.EscName, &e.EscPool, &scheduledTime, &e.DelayMS, &e.ErrorEncountered,
-		&e.RawText, &e.ErrorMessage,
+		&e.RawText, &e.ErrorMessage, &e.RawTextTruncated,
 	); err != nil {
 		return nil, fmt.Errorf("clickhouse: get entry: %w", err)
 	}
 	e.LogType = domain.LogType(logType)
 
 	if !scheduledTime.IsZero() {
-		e.ScheduledTime = &scheduledTime
+		e.ScheduledTime = domain.TimestampPtr(&scheduledTime)
 	}
 
 	return &e, nil
 }
 
+// ResolveEntryID returns the entry ID of the log line at fileNumber/lineNumber
+// within a job. Entry IDs are deterministic (see logparser.EntryID), so the
+// result is stable across re-ingestion of an unchanged file.
+func (c *ClickHouseClient) ResolveEntryID(ctx context.Context, tenantID, jobID string, fileNumber uint16, lineNumber uint32) (string, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	row := c.conn.QueryRow(ctx, `
+		SELECT entry_id
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+			AND file_number = @fileNumber AND line_number = @lineNumber
+		LIMIT 1
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("fileNumber", fileNumber),
+		clickhouse.Named("lineNumber", lineNumber),
+	)
+
+	var entryID string
+	if err := row.Scan(&entryID); err != nil {
+		return "", fmt.Errorf("clickhouse: resolve entry: %w", err)
+	}
+	return entryID, nil
+}
+
 // SearchEntries performs a paginated search over log entries with optional
 // filters. All queries are tenant-scoped.
 func (c *ClickHouseClient) SearchEntries(ctx context.Context, tenantID, jobID string, q SearchQuery) (*SearchResult, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	if !q.ExportMode {
+		ctx = c.limitSearch(ctx)
+	}
 	start := time.Now()
 
 	if q.PageSize <= 0 {
@@ -607,19 +779,151 @@ func (c *ClickHouseClient) SearchEntries(ctx context.Context, tenantID, jobID st
 		q.Page = 1
 	}
 
-	// Determine sort column and direction.
-	sortCol := "timestamp"
-	switch q.SortBy {
-	case "duration_ms", "line_number", "timestamp", "user", "log_type":
-		sortCol = q.SortBy
+	where, chArgs := searchWhere(tenantID, jobID, q)
+
+	// Count query.
+	countQuery := fmt.Sprintf("SELECT count() FROM log_entries WHERE %s", where)
+	var totalCount uint64
+	if err := c.conn.QueryRow(ctx, countQuery, chArgs...).Scan(&totalCount); err != nil {
+		return nil, searchError("search count", err)
+	}
+
+	// Data query.
+	offset := (q.Page - 1) * q.PageSize
+	computedSelect, dataArgs := computedColumns(q.Computed, chArgs)
+	dataQuery := fmt.Sprintf(`
+		SELECT
+			tenant_id, job_id, entry_id, line_number, file_number,
+			timestamp, ingested_at, log_type,
+			trace_id, rpc_id, thread_id,
+			queue, user,
+			duration_ms, queue_time_ms, success,
+			api_code, form, client, client_ip,
+			sql_table, sql_statement,
+			filter_name, filter_level, operation, request_id,
+			esc_name, esc_pool, scheduled_time, delay_ms, error_encountered,
+			raw_text, error_message, is_noise, raw_text_truncated%s
+		FROM log_entries
+		WHERE %s
+		ORDER BY %s
+		LIMIT %d OFFSET %d
+	`, computedSelect, where, q.orderBy(), q.PageSize, offset)
+
+	rows, err := c.conn.Query(ctx, dataQuery, dataArgs...)
+	if err != nil {
+		return nil, searchError("search query", err)
+	}
+	defer rows.Close()
+
+	var entries []domain.LogEntry
+	var computed []map[string]any
+	for rows.Next() {
+		var e domain.LogEntry
+		var logType string
+		var scheduledTime time.Time
+		dest := []any{
+			&e.TenantID, &e.JobID, &e.EntryID, &e.LineNumber, &e.FileNumber,
+			&e.Timestamp, &e.IngestedAt, &logType,
+			&e.TraceID, &e.RPCID, &e.ThreadID,
+			&e.Queue, &e.User,
+			&e.DurationMS, &e.QueueTimeMS, &e.Success,
+			&e.APICode, &e.Form, &e.Client, &e.ClientIP,
+			&e.SQLTable, &e.SQLStatement,
+			&e.FilterName, &e.FilterLevel, &e.Operation, &e.RequestID,
+			&e.EscName, &e.EscPool, &scheduledTime, &e.DelayMS, &e.ErrorEncountered,
+			&e.RawText, &e.ErrorMessage, &e.IsNoise, &e.RawTextTruncated,
+		}
+		values := computedDest(q.Computed)
+		if err := rows.Scan(append(dest, values...)...); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan entry: %w", err)
+		}
+		e.LogType = domain.LogType(logType)
+		if !scheduledTime.IsZero() {
+			e.ScheduledTime = domain.TimestampPtr(&scheduledTime)
+		}
+		entries = append(entries, e)
+		if len(q.Computed) > 0 {
+			computed = append(computed, computedValues(q.Computed, values))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, searchError("rows", err)
+	}
+
+	return &SearchResult{
+		Entries:    entries,
+		TotalCount: int64(totalCount),
+		TookMS:     int(time.Since(start).Milliseconds()),
+		Computed:   computed,
+	}, nil
+}
+
+// computedAlias is the SELECT alias of the i-th computed column.
+func computedAlias(i int) string {
+	return fmt.Sprintf("computed_%d", i)
+}
+
+// computedColumns returns the SELECT list suffix of the computed columns
+// and the search arguments extended with their parameters.
+func computedColumns(cols []search.CompiledColumn, args []any) (string, []any) {
+	if len(cols) == 0 {
+		return "", args
+	}
+	var sb strings.Builder
+	var named []driver.NamedValue
+	for i, col := range cols {
+		alias := computedAlias(i)
+		fmt.Fprintf(&sb, ",\n\t\t\t%s AS %s", namePlaceholders(col.SQL, col.Params, alias, &named), alias)
+	}
+	out := append(make([]any, 0, len(args)+len(named)), args...)
+	for _, na := range named {
+		out = append(out, clickhouse.Named(na.Name, na.Value))
+	}
+	return sb.String(), out
+}
+
+// computedDest returns a scan destination for each computed column.
+func computedDest(cols []search.CompiledColumn) []any {
+	dest := make([]any, len(cols))
+	for i, col := range cols {
+		switch col.Type {
+		case search.ComputedNumber:
+			dest[i] = new(float64)
+		case search.ComputedBool:
+			dest[i] = new(uint8)
+		default:
+			dest[i] = new(string)
+		}
 	}
-	sortDir := "DESC"
-	if q.SortOrder == "asc" || q.SortOrder == "ASC" {
-		sortDir = "ASC"
+	return dest
+}
+
+// computedValues maps the scanned computed values by column name. A number
+// that overflowed is null, as JSON has no infinity.
+func computedValues(cols []search.CompiledColumn, dest []any) map[string]any {
+	m := make(map[string]any, len(cols))
+	for i, col := range cols {
+		switch v := dest[i].(type) {
+		case *float64:
+			if math.IsInf(*v, 0) || math.IsNaN(*v) {
+				m[col.Name] = nil
+			} else {
+				m[col.Name] = *v
+			}
+		case *uint8:
+			m[col.Name] = *v != 0
+		case *string:
+			m[col.Name] = *v
+		}
 	}
+	return m
+}
 
-	// Build the WHERE clause dynamically.
-	where := "tenant_id = @tenantID AND job_id = @jobID"
+// searchWhere builds the WHERE condition of the entries a search matches,
+// with its named parameters.
+func searchWhere(tenantID, jobID string, q SearchQuery) (string, []any) {
+	where := searchScope(q)
 	namedArgs := []driver.NamedValue{
 		{Name: "tenantID", Value: tenantID},
 		{Name: "jobID", Value: jobID},
@@ -631,24 +935,11 @@ func (c *ClickHouseClient) SearchEntries(ctx context.Context, tenantID, jobID st
 			kqlSQL, kqlParams := parsed.ToClickHouseWhere()
 			// Convert positional ? params to named @kql_N params for
 			// compatibility with the rest of the named-arg query.
-			paramIdx := 0
-			var converted strings.Builder
-			for _, ch := range kqlSQL {
-				if ch == '?' && paramIdx < len(kqlParams) {
-					paramName := fmt.Sprintf("kql_%d", paramIdx)
-					converted.WriteString("@" + paramName)
-					namedArgs = append(namedArgs, driver.NamedValue{Name: paramName, Value: kqlParams[paramIdx]})
-					paramIdx++
-				} else {
-					converted.WriteRune(ch)
-				}
-			}
-			where += " AND (" + converted.String() + ")"
+			where += " AND (" + namePlaceholders(kqlSQL, kqlParams, "kql", &namedArgs) + ")"
 		} else {
-			// Fallback to ILIKE for unparseable queries
-			escaped := escapeLikePattern(q.Query)
-			where += " AND (raw_text ILIKE @query OR error_message ILIKE @query)"
-			namedArgs = append(namedArgs, driver.NamedValue{Name: "query", Value: "%" + escaped + "%"})
+			// An unparseable query is matched as free text, term by term.
+			textSQL, textParams := search.FreeTextWhere(q.Query)
+			where += " AND (" + namePlaceholders(textSQL, textParams, "text", &namedArgs) + ")"
 		}
 	}
 
@@ -688,80 +979,88 @@ func (c *ClickHouseClient) SearchEntries(ctx context.Context, tenantID, jobID st
 	for i, na := range namedArgs {
 		chArgs[i] = clickhouse.Named(na.Name, na.Value)
 	}
+	return where, chArgs
+}
 
-	// Count query.
-	countQuery := fmt.Sprintf("SELECT count() FROM log_entries WHERE %s", where)
-	var totalCount uint64
-	if err := c.conn.QueryRow(ctx, countQuery, chArgs...).Scan(&totalCount); err != nil {
-		return nil, fmt.Errorf("clickhouse: search count: %w", err)
-	}
-
-	// Data query.
-	offset := (q.Page - 1) * q.PageSize
-	dataQuery := fmt.Sprintf(`
-		SELECT
-			tenant_id, job_id, entry_id, line_number, file_number,
-			timestamp, ingested_at, log_type,
-			trace_id, rpc_id, thread_id,
-			queue, user,
-			duration_ms, queue_time_ms, success,
-			api_code, form,
-			sql_table, sql_statement,
-			filter_name, filter_level, operation, request_id,
-			esc_name, esc_pool, scheduled_time, delay_ms, error_encountered,
-			raw_text, error_message
-		FROM log_entries
-		WHERE %s
-		ORDER BY %s %s
-		LIMIT %d OFFSET %d
-	`, where, sortCol, sortDir, q.PageSize, offset)
-
-	rows, err := c.conn.Query(ctx, dataQuery, chArgs...)
-	if err != nil {
-		return nil, fmt.Errorf("clickhouse: search query: %w", err)
-	}
-	defer rows.Close()
-
-	var entries []domain.LogEntry
-	for rows.Next() {
-		var e domain.LogEntry
-		var logType string
-		var scheduledTime time.Time
-		if err := rows.Scan(
-			&e.TenantID, &e.JobID, &e.EntryID, &e.LineNumber, &e.FileNumber,
-			&e.Timestamp, &e.IngestedAt, &logType,
-			&e.TraceID, &e.RPCID, &e.ThreadID,
-			&e.Queue, &e.User,
-			&e.DurationMS, &e.QueueTimeMS, &e.Success,
-			&e.APICode, &e.Form,
-			&e.SQLTable, &e.SQLStatement,
-			&e.FilterName, &e.FilterLevel, &e.Operation, &e.RequestID,
-			&e.EscName, &e.EscPool, &scheduledTime, &e.DelayMS, &e.ErrorEncountered,
-			&e.RawText, &e.ErrorMessage,
-		); err != nil {
-			return nil, fmt.Errorf("clickhouse: scan entry: %w", err)
-		}
-		e.LogType = domain.LogType(logType)
-		if !scheduledTime.IsZero() {
-			e.ScheduledTime = &scheduledTime
+// namePlaceholders replaces the positional ? placeholders of sql with
+// named @prefix_N ones, appending their values to args.
+func namePlaceholders(sql string, params []interface{}, prefix string, args *[]driver.NamedValue) string {
+	paramIdx := 0
+	var converted strings.Builder
+	for _, ch := range sql {
+		if ch == '?' && paramIdx < len(params) {
+			paramName := fmt.Sprintf("%s_%d", prefix, paramIdx)
+			converted.WriteString("@" + paramName)
+			*args = append(*args, driver.NamedValue{Name: paramName, Value: params[paramIdx]})
+			paramIdx++
+		} else {
+			converted.WriteRune(ch)
 		}
-		entries = append(entries, e)
 	}
+	return converted.String()
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("clickhouse: rows: %w", err)
+// searchScope is the WHERE condition selecting the entries of a job a
+// search may match.
+func searchScope(q SearchQuery) string {
+	if q.IncludeNoise {
+		return "tenant_id = @tenantID AND job_id = @jobID"
 	}
+	return "tenant_id = @tenantID AND job_id = @jobID AND NOT is_noise"
+}
 
-	return &SearchResult{
-		Entries:    entries,
-		TotalCount: int64(totalCount),
-		TookMS:     int(time.Since(start).Milliseconds()),
-	}, nil
+// TraceQuery pages through the entries of a trace. After resumes behind
+// the last entry of a previous page; Limit <= 0 returns every entry.
+type TraceQuery struct {
+	After *TraceCursor
+	Limit int
+}
+
+// TraceCursor is the position of an entry in a trace, which is ordered by
+// timestamp and then entry ID.
+type TraceCursor struct {
+	Timestamp time.Time
+	EntryID   string
 }
 
 // GetTraceEntries returns all log entries sharing a trace_id, ordered by
 // timestamp. Results are tenant-scoped.
 func (c *ClickHouseClient) GetTraceEntries(ctx context.Context, tenantID, jobID, traceID string) ([]domain.LogEntry, error) {
+	ctx = replicaRead(ctx)
+	var entries []domain.LogEntry
+	err := c.GetTraceEntriesStream(ctx, tenantID, jobID, traceID, TraceQuery{}, func(e domain.LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetTraceEntriesStream calls fn for each entry of a trace in timestamp
+// order as the rows arrive, so that traces of any size are read in
+// constant memory. An error returned by fn stops the query and is
+// returned as is. Results are tenant-scoped.
+func (c *ClickHouseClient) GetTraceEntriesStream(ctx context.Context, tenantID, jobID, traceID string, q TraceQuery, fn func(domain.LogEntry) error) error {
+	ctx = replicaRead(ctx)
+	where := "tenant_id = @tenantID AND job_id = @jobID AND trace_id = @traceID"
+	namedArgs := []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("traceID", traceID),
+	}
+	if q.After != nil {
+		where += " AND (timestamp, entry_id) > (@afterTimestamp, @afterEntryID)"
+		namedArgs = append(namedArgs,
+			clickhouse.Named("afterTimestamp", q.After.Timestamp),
+			clickhouse.Named("afterEntryID", q.After.EntryID))
+	}
+	limit := ""
+	if q.Limit > 0 {
+		limit = fmt.Sprintf("LIMIT %d", q.Limit)
+	}
+
 	rows, err := c.conn.Query(ctx, `
 		SELECT
 			tenant_id, job_id, entry_id, line_number, file_number,
@@ -769,25 +1068,20 @@ func (c *ClickHouseClient) GetTraceEntries(ctx context.Context, tenantID, jobID,
 			trace_id, rpc_id, thread_id,
 			queue, user,
 			duration_ms, queue_time_ms, success,
-			api_code, form,
+			api_code, form, client, client_ip,
 			sql_table, sql_statement,
 			filter_name, filter_level, operation, request_id,
 			esc_name, esc_pool, scheduled_time, delay_ms, error_encountered,
-			raw_text, error_message
+			raw_text, error_message, raw_text_truncated
 		FROM log_entries
-		WHERE tenant_id = @tenantID AND job_id = @jobID AND trace_id = @traceID
-		ORDER BY timestamp ASC
-	`,
-		clickhouse.Named("tenantID", tenantID),
-		clickhouse.Named("jobID", jobID),
-		clickhouse.Named("traceID", traceID),
-	)
+		WHERE `+where+`
+		ORDER BY timestamp ASC, entry_id ASC
+		`+limit, namedArgs...)
 	if err != nil {
-		return nil, fmt.Errorf("clickhouse: trace query: %w", err)
+		return fmt.Errorf("clickhouse: trace query: %w", err)
 	}
 	defer rows.Close()
 
-	var entries []domain.LogEntry
 	for rows.Next() {
 		var e domain.LogEntry
 		var logType string
@@ -798,63 +1092,122 @@ func (c *ClickHouseClient) GetTraceEntries(ctx context.Context, tenantID, jobID,
 			&e.TraceID, &e.RPCID, &e.ThreadID,
 			&e.Queue, &e.User,
 			&e.DurationMS, &e.QueueTimeMS, &e.Success,
-			&e.APICode, &e.Form,
+			&e.APICode, &e.Form, &e.Client, &e.ClientIP,
 			&e.SQLTable, &e.SQLStatement,
 			&e.FilterName, &e.FilterLevel, &e.Operation, &e.RequestID,
 			&e.EscName, &e.EscPool, &scheduledTime, &e.DelayMS, &e.ErrorEncountered,
-			&e.RawText, &e.ErrorMessage,
+			&e.RawText, &e.ErrorMessage, &e.RawTextTruncated,
 		); err != nil {
-			return nil, fmt.Errorf("clickhouse: scan trace entry: %w", err)
+			return fmt.Errorf("clickhouse: scan trace entry: %w", err)
 		}
 		e.LogType = domain.LogType(logType)
 		if !scheduledTime.IsZero() {
-			e.ScheduledTime = &scheduledTime
+			e.ScheduledTime = domain.TimestampPtr(&scheduledTime)
+		}
+		if err := fn(e); err != nil {
+			return err
 		}
-		entries = append(entries, e)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("clickhouse: trace rows: %w", err)
+		return fmt.Errorf("clickhouse: trace rows: %w", err)
 	}
-
-	return entries, nil
+	return nil
 }
 
-// GetAggregates returns performance aggregates grouped by form (API), user (API), and table (SQL).
+// GetAggregates returns performance aggregates grouped by form, client and
+// client IP (API), table (SQL) and name (filters). Counts and totals of a
+// sampled capture are estimates; unique traces count the stored traces.
 func (c *ClickHouseClient) GetAggregates(ctx context.Context, tenantID, jobID string) (*domain.AggregatesResponse, error) {
+	return c.getAggregates(ctx, tenantID, jobID, nil)
+}
+
+// GetAggregatesPage is GetAggregates returning one page of the groups of
+// each section, with the number of groups matching the page's name filter.
+// Grand totals cover every group, whatever the page.
+func (c *ClickHouseClient) GetAggregatesPage(ctx context.Context, tenantID, jobID string, page domain.AggregatePage) (*domain.AggregatesResponse, error) {
+	if !page.SortBy.Valid() {
+		return nil, fmt.Errorf("clickhouse: invalid aggregate sort: %s", page.SortBy)
+	}
+	resp, err := c.getAggregates(ctx, tenantID, jobID, &page)
+	if err != nil {
+		return nil, err
+	}
+	resp.Page = &page
+	return resp, nil
+}
+
+func (c *ClickHouseClient) getAggregates(ctx context.Context, tenantID, jobID string, page *domain.AggregatePage) (*domain.AggregatesResponse, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	resp := &domain.AggregatesResponse{}
+	est, err := c.sampleEstimate(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: aggregates: %w", err)
+	}
+	resp.Estimate = est
 
 	// API by form
-	apiByForm, err := c.queryAggregateGroups(ctx, tenantID, jobID, "API", "form")
+	apiByForm, err := c.queryAggregateGroups(ctx, tenantID, jobID, "API", "form", page)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: aggregates api by form: %w", err)
 	}
-	if len(apiByForm.Groups) > 0 {
+	if len(apiByForm.Groups) > 0 || apiByForm.GrandTotal != nil {
 		resp.API = apiByForm
 	}
 
+	// API by client program and by client address
+	apiByClient, err := c.queryAggregateGroups(ctx, tenantID, jobID, "API", "client", page)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: aggregates api by client: %w", err)
+	}
+	if len(apiByClient.Groups) > 0 || apiByClient.GrandTotal != nil {
+		resp.APIByClient = apiByClient
+	}
+
+	apiByClientIP, err := c.queryAggregateGroups(ctx, tenantID, jobID, "API", "client_ip", page)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: aggregates api by client ip: %w", err)
+	}
+	if len(apiByClientIP.Groups) > 0 || apiByClientIP.GrandTotal != nil {
+		resp.APIByClientIP = apiByClientIP
+	}
+
 	// SQL by table
-	sqlByTable, err := c.queryAggregateGroups(ctx, tenantID, jobID, "SQL", "sql_table")
+	sqlByTable, err := c.queryAggregateGroups(ctx, tenantID, jobID, "SQL", "sql_table", page)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: aggregates sql by table: %w", err)
 	}
-	if len(sqlByTable.Groups) > 0 {
+	if len(sqlByTable.Groups) > 0 || sqlByTable.GrandTotal != nil {
 		resp.SQL = sqlByTable
 	}
 
 	// Filter by name
-	filterByName, err := c.queryAggregateGroups(ctx, tenantID, jobID, "FLTR", "filter_name")
+	filterByName, err := c.queryAggregateGroups(ctx, tenantID, jobID, "FLTR", "filter_name", page)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: aggregates filter by name: %w", err)
 	}
-	if len(filterByName.Groups) > 0 {
+	if len(filterByName.Groups) > 0 || filterByName.GrandTotal != nil {
 		resp.Filter = filterByName
 	}
 
 	return resp, nil
 }
 
-func (c *ClickHouseClient) queryAggregateGroups(ctx context.Context, tenantID, jobID, logType, groupCol string) (*domain.AggregateSection, error) {
+// aggregateSortColumns are the columns of the aggregate group query the
+// aggregate sorts order by.
+var aggregateSortColumns = map[domain.AggregateSort]string{
+	domain.AggregateSortCount:     "cnt",
+	domain.AggregateSortTotalMS:   "total_ms",
+	domain.AggregateSortAvgMS:     "avg_ms",
+	domain.AggregateSortErrorRate: "error_rate",
+}
+
+// queryAggregateGroups aggregates the entries of logType by groupCol. With a
+// nil page every group is returned, by total time, and the grand total is
+// summed over them; otherwise the page is selected in ClickHouse and the
+// grand total read by a parallel aggregate over all groups.
+func (c *ClickHouseClient) queryAggregateGroups(ctx context.Context, tenantID, jobID, logType, groupCol string, page *domain.AggregatePage) (*domain.AggregateSection, error) {
 	var groupExpr, filterExpr string
 	switch groupCol {
 	case "form":
@@ -865,32 +1218,57 @@ func (c *ClickHouseClient) queryAggregateGroups(ctx context.Context, tenantID, j
 		groupExpr, filterExpr = "filter_name", "filter_name != ''"
 	case "user":
 		groupExpr, filterExpr = "user", "user != ''"
+	case "client":
+		groupExpr, filterExpr = "client", "client != ''"
+	case "client_ip":
+		groupExpr, filterExpr = "client_ip", "client_ip != ''"
 	default:
 		return nil, fmt.Errorf("clickhouse: invalid aggregate group column: %s", groupCol)
 	}
 
-	query := fmt.Sprintf(`
-		SELECT
-			%s AS name,
-			count() AS cnt,
-			toInt64(sum(duration_ms)) AS total_ms,
-			avg(duration_ms) AS avg_ms,
+	args := []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("logType", logType),
+	}
+	pageArgs := args
+	var nameExpr string
+	orderBy := "total_ms DESC"
+	if page != nil {
+		sortCol, ok := aggregateSortColumns[page.SortBy]
+		if !ok {
+			return nil, fmt.Errorf("clickhouse: invalid aggregate sort: %s", page.SortBy)
+		}
+		dir := "DESC"
+		if page.SortOrder == "asc" {
+			dir = "ASC"
+		}
+		orderBy = fmt.Sprintf("%s %s, name ASC LIMIT %d OFFSET %d", sortCol, dir, page.Limit, page.Offset)
+		if page.Name != "" {
+			nameExpr = fmt.Sprintf(" AND positionCaseInsensitiveUTF8(%s, @name) > 0", groupExpr)
+			pageArgs = append(args[:len(args):len(args)], clickhouse.Named("name", page.Name))
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS name,
+			toInt64(sum(sample_weight)) AS cnt,
+			toInt64(sum(duration_ms * sample_weight)) AS total_ms,
+			avgWeighted(duration_ms, sample_weight) AS avg_ms,
 			toInt64(min(duration_ms)) AS min_ms,
 			toInt64(max(duration_ms)) AS max_ms,
-			countIf(success = false) AS error_count,
-			if(count() > 0, countIf(success = false) / count(), 0) AS error_rate,
-			uniqExact(trace_id) AS unique_traces
+			toInt64(sumIf(sample_weight, success = false)) AS error_count,
+			if(count() > 0, sumIf(sample_weight, success = false) / sum(sample_weight), 0) AS error_rate,
+			uniqExact(trace_id) AS unique_traces,
+			%s
 		FROM log_entries
-		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = @logType AND %s
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = @logType AND %s%s
 		GROUP BY name
-		ORDER BY total_ms DESC
-	`, groupExpr, filterExpr)
+		ORDER BY %s
+	`, groupExpr, timedDurationColumns, filterExpr, nameExpr, orderBy)
 
-	rows, err := c.conn.Query(ctx, query,
-		clickhouse.Named("tenantID", tenantID),
-		clickhouse.Named("jobID", jobID),
-		clickhouse.Named("logType", logType),
-	)
+	rows, err := c.conn.Query(ctx, query, pageArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: aggregates query (%s/%s): %w", logType, groupCol, err)
 	}
@@ -906,6 +1284,7 @@ func (c *ClickHouseClient) queryAggregateGroups(ctx context.Context, tenantID, j
 		if err := rows.Scan(
 			&g.Name, &g.Count, &g.TotalMS, &g.AvgMS,
 			&g.MinMS, &g.MaxMS, &g.ErrorCount, &g.ErrorRate, &g.UniqueTraces,
+			&g.P95MS, &g.TimedCount, &g.AvgTimedMS, &g.P95TimedMS,
 		); err != nil {
 			return nil, fmt.Errorf("clickhouse: aggregates scan (%s/%s): %w", logType, groupCol, err)
 		}
@@ -926,6 +1305,15 @@ func (c *ClickHouseClient) queryAggregateGroups(ctx context.Context, tenantID, j
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("clickhouse: aggregates rows (%s/%s): %w", logType, groupCol, err)
 	}
+	if page != nil {
+		if section.Groups == nil {
+			section.Groups = []domain.AggregateGroup{}
+		}
+		if err := c.aggregateGrandTotal(ctx, section, groupExpr, filterExpr, page.Name, args); err != nil {
+			return nil, fmt.Errorf("clickhouse: aggregates grand total (%s/%s): %w", logType, groupCol, err)
+		}
+		return section, nil
+	}
 
 	if grandCount > 0 {
 		section.GrandTotal = &domain.AggregateGroup{
@@ -939,16 +1327,79 @@ func (c *ClickHouseClient) queryAggregateGroups(ctx context.Context, tenantID, j
 			ErrorRate:    float64(grandErrors) / float64(grandCount),
 			UniqueTraces: grandTraces,
 		}
+		// Percentiles do not add up over groups, so the grand total's are
+		// read over all of them.
+		g := section.GrandTotal
+		if err := c.conn.QueryRow(ctx, fmt.Sprintf(`
+			SELECT %s
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = @logType AND %s
+		`, timedDurationColumns, filterExpr), args...).Scan(&g.P95MS, &g.TimedCount, &g.AvgTimedMS, &g.P95TimedMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: aggregates grand total (%s/%s): %w", logType, groupCol, err)
+		}
 	}
 
 	return section, nil
 }
 
+// aggregateGrandTotal sets the grand total of a paged aggregate section from
+// one aggregate over all of its entries, with the number of groups whose
+// name contains name. Unique traces are counted per group, as when they are
+// summed over the groups of a whole section.
+func (c *ClickHouseClient) aggregateGrandTotal(ctx context.Context, section *domain.AggregateSection, groupExpr, filterExpr, name string, args []any) error {
+	query := fmt.Sprintf(`
+		SELECT
+			toInt64(sum(sample_weight)) AS cnt,
+			toInt64(sum(duration_ms * sample_weight)) AS total_ms,
+			toInt64(min(duration_ms)) AS min_ms,
+			toInt64(max(duration_ms)) AS max_ms,
+			toInt64(sumIf(sample_weight, success = false)) AS error_count,
+			toUInt64(uniqExact(%[1]s, trace_id)) AS unique_traces,
+			toUInt64(uniqExactIf(%[1]s, positionCaseInsensitiveUTF8(%[1]s, @name) > 0)) AS total_groups,
+			%[2]s
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = @logType AND %[3]s
+	`, groupExpr, timedDurationColumns, filterExpr)
+
+	g := domain.AggregateGroup{Name: "Total"}
+	var traces, groups uint64
+	if err := c.conn.QueryRow(ctx, query, append(args[:len(args):len(args)], clickhouse.Named("name", name))...).Scan(
+		&g.Count, &g.TotalMS, &g.MinMS, &g.MaxMS, &g.ErrorCount, &traces, &groups,
+		&g.P95MS, &g.TimedCount, &g.AvgTimedMS, &g.P95TimedMS,
+	); err != nil {
+		return err
+	}
+	section.TotalGroups = int(groups)
+	if g.Count == 0 {
+		return nil
+	}
+	g.AvgMS = float64(g.TotalMS) / float64(g.Count)
+	g.ErrorRate = float64(g.ErrorCount) / float64(g.Count)
+	g.UniqueTraces = int(traces)
+	section.GrandTotal = &g
+	return nil
+}
+
+// timedDurationColumns select the 95th percentile duration of a group of
+// entries and the count, average and 95th percentile duration of those
+// timed above zero, zero for a group without any.
+const timedDurationColumns = `toFloat64(quantileWeighted(0.95)(duration_ms, sample_weight)) AS p95_ms,
+			toInt64(sumIf(sample_weight, duration_ms > 0)) AS timed_count,
+			if(timed_count > 0, avgWeightedIf(duration_ms, sample_weight, duration_ms > 0), 0) AS avg_timed_ms,
+			if(timed_count > 0, toFloat64(quantileWeightedIf(0.95)(duration_ms, sample_weight, duration_ms > 0)), 0) AS p95_timed_ms`
+
+// exceptionErrorCodeExpr normalizes an entry's error message into the error
+// code used to group exceptions. The heatmap uses the same expression so its
+// rows line up with the exceptions list.
+const exceptionErrorCodeExpr = `if(error_message != '', substring(error_message, 1, 100), 'Unknown Error')`
+
 // GetExceptions returns exception entries grouped by error code with frequency and error rates.
 func (c *ClickHouseClient) GetExceptions(ctx context.Context, tenantID, jobID string) (*domain.ExceptionsResponse, error) {
-	rows, err := c.conn.Query(ctx, `
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(`
 		SELECT
-			if(error_message != '', substring(error_message, 1, 100), 'Unknown Error') AS error_code,
+			%s AS error_code,
 			any(error_message) AS message,
 			count() AS cnt,
 			min(timestamp) AS first_seen,
@@ -963,7 +1414,7 @@ func (c *ClickHouseClient) GetExceptions(ctx context.Context, tenantID, jobID st
 		WHERE tenant_id = @tenantID AND job_id = @jobID AND success = false
 		GROUP BY error_code
 		ORDER BY cnt DESC
-	`,
+	`, exceptionErrorCodeExpr),
 		clickhouse.Named("tenantID", tenantID),
 		clickhouse.Named("jobID", jobID),
 	)
@@ -1007,12 +1458,14 @@ func (c *ClickHouseClient) GetExceptions(ctx context.Context, tenantID, jobID st
 		resp.TopCodes = append(resp.TopCodes, ex.ErrorCode)
 	}
 
-	// Error rates per log type
+	// Error rates per log type, over the sample weights of a sampled
+	// capture.
 	rateRows, err := c.conn.Query(ctx, `
 		SELECT
 			log_type,
-			countIf(success = false) AS errors,
-			count() AS total
+			sumIf(sample_weight, success = false) AS errors,
+			sum(sample_weight) AS total,
+			max(sample_weight) AS max_weight
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID
 		GROUP BY log_type
@@ -1025,12 +1478,15 @@ func (c *ClickHouseClient) GetExceptions(ctx context.Context, tenantID, jobID st
 	}
 	defer rateRows.Close()
 
+	var maxWeight uint32
 	for rateRows.Next() {
 		var lt string
 		var errors, total int64
-		if err := rateRows.Scan(&lt, &errors, &total); err != nil {
+		var weight uint32
+		if err := rateRows.Scan(&lt, &errors, &total, &weight); err != nil {
 			return nil, fmt.Errorf("clickhouse: error rates scan: %w", err)
 		}
+		maxWeight = max(maxWeight, weight)
 		if total > 0 {
 			resp.ErrorRates[lt] = float64(errors) / float64(total)
 		}
@@ -1039,12 +1495,236 @@ func (c *ClickHouseClient) GetExceptions(ctx context.Context, tenantID, jobID st
 	if err := rateRows.Err(); err != nil {
 		return nil, fmt.Errorf("clickhouse: error rates rows: %w", err)
 	}
+	resp.Estimate = domain.NewEstimate(maxWeight)
 
 	return resp, nil
 }
 
-// GetGaps detects time gaps between consecutive log entries.
+// heatmapBucketSizes maps the bucket values accepted by the heatmap endpoint
+// to ClickHouse interval expressions. "auto" defers to computeBucketSize.
+var heatmapBucketSizes = map[string]string{
+	"1m": "1 MINUTE",
+	"5m": "5 MINUTE",
+}
+
+// maxHeatmapBuckets bounds the number of columns in a heatmap. An explicit
+// bucket size that would exceed it falls back to the automatic size.
+const maxHeatmapBuckets = 1440
+
+// defaultHeatmapLimit is the number of error code rows returned when the
+// caller does not specify a limit.
+const defaultHeatmapLimit = 10
+
+// IsValidHeatmapBucket reports whether bucket is accepted by GetErrorHeatmap.
+func IsValidHeatmapBucket(bucket string) bool {
+	if bucket == "auto" {
+		return true
+	}
+	_, ok := heatmapBucketSizes[bucket]
+	return ok
+}
+
+// heatmapCell is one (error code, bucket) count returned by the heatmap query.
+type heatmapCell struct {
+	ErrorCode string
+	Bucket    time.Time
+	Count     int64
+}
+
+// GetErrorHeatmap returns a dense error code × time bucket matrix for a job.
+// Rows are the top `limit` error codes by count (grouped like GetExceptions);
+// all remaining codes are folded into a single "other" row. Every bucket in
+// the job's time range is present, including those with no errors.
+func (c *ClickHouseClient) GetErrorHeatmap(ctx context.Context, tenantID, jobID, bucket string, limit int) (*domain.ErrorHeatmapResponse, error) {
+	ctx = replicaRead(ctx)
+	var rangeStart, rangeEnd time.Time
+	err := c.conn.QueryRow(ctx, `
+		SELECT min(timestamp), max(timestamp)
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	).Scan(&rangeStart, &rangeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: heatmap time range: %w", err)
+	}
+
+	bucketSize := resolveHeatmapBucketSize(bucket, rangeStart, rangeEnd)
+	if rangeStart.IsZero() && rangeEnd.IsZero() {
+		return buildErrorHeatmap(nil, rangeStart, rangeEnd, bucketSize, limit), nil
+	}
+
+	// INTERVAL cannot be passed as a named parameter; bucketSize always comes
+	// from heatmapBucketSizes or computeBucketSize, never from user input.
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			%s AS error_code,
+			toStartOfInterval(timestamp, INTERVAL %s) AS bucket,
+			count() AS cnt
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND success = false
+		GROUP BY error_code, bucket
+	`, exceptionErrorCodeExpr, bucketSize),
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: heatmap query: %w", err)
+	}
+	defer rows.Close()
+
+	var cells []heatmapCell
+	for rows.Next() {
+		var cell heatmapCell
+		var cnt uint64
+		if err := rows.Scan(&cell.ErrorCode, &cell.Bucket, &cnt); err != nil {
+			return nil, fmt.Errorf("clickhouse: heatmap scan: %w", err)
+		}
+		cell.Count = int64(cnt)
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: heatmap rows: %w", err)
+	}
+
+	return buildErrorHeatmap(cells, rangeStart, rangeEnd, bucketSize, limit), nil
+}
+
+// resolveHeatmapBucketSize returns the ClickHouse interval for the requested
+// bucket, falling back to computeBucketSize for "auto", unknown values, and
+// explicit sizes that would produce more than maxHeatmapBuckets columns.
+func resolveHeatmapBucketSize(bucket string, rangeStart, rangeEnd time.Time) string {
+	auto := computeBucketSize(rangeStart, rangeEnd)
+	size, ok := heatmapBucketSizes[bucket]
+	if !ok {
+		return auto
+	}
+	if interval := intervalDuration(size); interval > 0 && rangeEnd.Sub(rangeStart)/interval >= maxHeatmapBuckets {
+		return auto
+	}
+	return size
+}
+
+// intervalDuration converts a ClickHouse interval expression such as
+// "5 MINUTE" into a time.Duration. Returns 0 for unrecognized input.
+func intervalDuration(interval string) time.Duration {
+	parts := strings.Fields(interval)
+	if len(parts) != 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return 0
+	}
+	switch parts[1] {
+	case "SECOND":
+		return time.Duration(n) * time.Second
+	case "MINUTE":
+		return time.Duration(n) * time.Minute
+	case "HOUR":
+		return time.Duration(n) * time.Hour
+	default:
+		return 0
+	}
+}
+
+// buildErrorHeatmap turns sparse heatmap cells into a dense matrix covering
+// every bucket between rangeStart and rangeEnd. Buckets are aligned the same
+// way as ClickHouse's toStartOfInterval for intervals that divide a day.
+func buildErrorHeatmap(cells []heatmapCell, rangeStart, rangeEnd time.Time, bucketSize string, limit int) *domain.ErrorHeatmapResponse {
+	if limit <= 0 {
+		limit = defaultHeatmapLimit
+	}
+
+	resp := &domain.ErrorHeatmapResponse{
+		BucketSize: bucketSize,
+		Buckets:    []domain.Timestamp{},
+		Rows:       []domain.ErrorHeatmapRow{},
+	}
+
+	interval := intervalDuration(bucketSize)
+	if interval <= 0 || (rangeStart.IsZero() && rangeEnd.IsZero()) {
+		return resp
+	}
+
+	first := rangeStart.UTC().Truncate(interval)
+	last := rangeEnd.UTC().Truncate(interval)
+	n := int(last.Sub(first)/interval) + 1
+	for i := 0; i < n; i++ {
+		resp.Buckets = append(resp.Buckets, domain.NewTimestamp(first.Add(time.Duration(i)*interval)))
+	}
+
+	totals := make(map[string]int64)
+	for _, cell := range cells {
+		totals[cell.ErrorCode] += cell.Count
+	}
+	codes := make([]string, 0, len(totals))
+	for code := range totals {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if totals[codes[i]] != totals[codes[j]] {
+			return totals[codes[i]] > totals[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+
+	rowIndex := make(map[string]int, len(codes))
+	for i, code := range codes {
+		if i >= limit {
+			break
+		}
+		rowIndex[code] = i
+		resp.Rows = append(resp.Rows, domain.ErrorHeatmapRow{
+			ErrorCode: code,
+			Counts:    make([]int64, n),
+		})
+	}
+	otherIdx := -1
+	if len(codes) > limit {
+		otherIdx = len(resp.Rows)
+		resp.Rows = append(resp.Rows, domain.ErrorHeatmapRow{
+			ErrorCode: domain.ErrorHeatmapOtherCode,
+			Counts:    make([]int64, n),
+			IsOther:   true,
+		})
+	}
+
+	for _, cell := range cells {
+		idx := int(cell.Bucket.UTC().Truncate(interval).Sub(first) / interval)
+		if idx < 0 {
+			idx = 0
+		} else if idx >= n {
+			idx = n - 1
+		}
+		row, ok := rowIndex[cell.ErrorCode]
+		if !ok {
+			row = otherIdx
+		}
+		resp.Rows[row].Counts[idx] += cell.Count
+		resp.Rows[row].Total += cell.Count
+		resp.TotalCount += cell.Count
+	}
+
+	for i := range resp.Rows {
+		peak := 0
+		for j, cnt := range resp.Rows[i].Counts {
+			if cnt > resp.Rows[i].Counts[peak] {
+				peak = j
+			}
+		}
+		resp.Rows[i].PeakBucket = peak
+	}
+
+	return resp
+}
+
+// GetGaps detects time gaps between consecutive log entries and attaches a
+// root-cause hint to each (see classifyGap).
 func (c *ClickHouseClient) GetGaps(ctx context.Context, tenantID, jobID string) (*domain.GapsResponse, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	resp := &domain.GapsResponse{
 		Gaps:        []domain.GapEntry{},
 		QueueHealth: []domain.QueueHealthSummary{},
@@ -1054,7 +1734,8 @@ func (c *ClickHouseClient) GetGaps(ctx context.Context, tenantID, jobID string)
 	lineRows, err := c.conn.Query(ctx, `
 		SELECT
 			start_time, end_time, gap_ms,
-			before_line, after_line, log_type
+			before_line, after_line, log_type,
+			before_file, after_file
 		FROM (
 			SELECT
 				timestamp AS start_time,
@@ -1062,7 +1743,9 @@ func (c *ClickHouseClient) GetGaps(ctx context.Context, tenantID, jobID string)
 				dateDiff('millisecond', timestamp, neighbor(timestamp, 1)) AS gap_ms,
 				line_number AS before_line,
 				neighbor(line_number, 1) AS after_line,
-				log_type
+				log_type,
+				file_number AS before_file,
+				neighbor(file_number, 1) AS after_file
 			FROM log_entries
 			WHERE tenant_id = @tenantID AND job_id = @jobID
 			ORDER BY timestamp ASC
@@ -1079,23 +1762,33 @@ func (c *ClickHouseClient) GetGaps(ctx context.Context, tenantID, jobID string)
 	}
 	defer lineRows.Close()
 
+	var beforeFiles, afterFiles []int
 	for lineRows.Next() {
 		var g domain.GapEntry
 		var beforeLine, afterLine uint32
+		var beforeFile, afterFile uint16
 		if err := lineRows.Scan(
 			&g.StartTime, &g.EndTime, &g.DurationMS,
 			&beforeLine, &afterLine, &g.LogType,
+			&beforeFile, &afterFile,
 		); err != nil {
 			return nil, fmt.Errorf("clickhouse: line gaps scan: %w", err)
 		}
 		g.BeforeLine = int(beforeLine)
 		g.AfterLine = int(afterLine)
 		resp.Gaps = append(resp.Gaps, g)
+		beforeFiles = append(beforeFiles, int(beforeFile))
+		afterFiles = append(afterFiles, int(afterFile))
 	}
 	if err := lineRows.Err(); err != nil {
 		return nil, fmt.Errorf("clickhouse: line gaps rows: %w", err)
 	}
 
+	// Root-cause hints are best effort: gaps are still useful without them.
+	if err := c.attachGapHints(ctx, tenantID, jobID, resp.Gaps, beforeFiles, afterFiles); err != nil {
+		slog.Warn("gap hints unavailable", "job_id", jobID, "error", err)
+	}
+
 	// Queue health
 	qRows, err := c.conn.Query(ctx, `
 		SELECT
@@ -1134,6 +1827,8 @@ func (c *ClickHouseClient) GetGaps(ctx context.Context, tenantID, jobID string)
 
 // GetThreadStats returns per-thread utilization statistics.
 func (c *ClickHouseClient) GetThreadStats(ctx context.Context, tenantID, jobID string) (*domain.ThreadStatsResponse, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	rows, err := c.conn.Query(ctx, `
 		SELECT
 			thread_id,
@@ -1147,8 +1842,13 @@ func (c *ClickHouseClient) GetThreadStats(ctx context.Context, tenantID, jobID s
 				least((sum(duration_ms) / dateDiff('millisecond', min(timestamp), max(timestamp))) * 100, 100),
 				0
 			) AS busy_pct,
-			formatDateTime(min(timestamp), '%Y-%m-%d %H:%M:%S') AS active_start,
-			formatDateTime(max(timestamp), '%Y-%m-%d %H:%M:%S') AS active_end
+			min(timestamp) AS active_start,
+			max(timestamp) AS active_end,
+			if(
+				dateDiff('millisecond', min(timestamp), max(timestamp)) > 0,
+				least((sum(greatest(duration_ms, @resolution)) / dateDiff('millisecond', min(timestamp), max(timestamp))) * 100, 100),
+				0
+			) AS busy_pct_resolved
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID AND thread_id != ''
 		GROUP BY thread_id
@@ -1156,6 +1856,7 @@ func (c *ClickHouseClient) GetThreadStats(ctx context.Context, tenantID, jobID s
 	`,
 		clickhouse.Named("tenantID", tenantID),
 		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("resolution", domain.DurationResolutionMS),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: thread stats: %w", err)
@@ -1167,14 +1868,19 @@ func (c *ClickHouseClient) GetThreadStats(ctx context.Context, tenantID, jobID s
 	}
 
 	for rows.Next() {
-		var t domain.ThreadStatsEntry
+		var (
+			t                      domain.ThreadStatsEntry
+			activeStart, activeEnd time.Time
+		)
 		if err := rows.Scan(
 			&t.ThreadID, &t.TotalCalls, &t.TotalMS, &t.AvgMS,
 			&t.MaxMS, &t.ErrorCount, &t.BusyPct,
-			&t.ActiveStart, &t.ActiveEnd,
+			&activeStart, &activeEnd, &t.BusyPctResolved,
 		); err != nil {
 			return nil, fmt.Errorf("clickhouse: thread stats scan: %w", err)
 		}
+		t.ActiveStart = domain.TimestampPtr(&activeStart)
+		t.ActiveEnd = domain.TimestampPtr(&activeEnd)
 		resp.Threads = append(resp.Threads, t)
 		resp.TotalThreads++
 	}
@@ -1186,42 +1892,113 @@ func (c *ClickHouseClient) GetThreadStats(ctx context.Context, tenantID, jobID s
 	return resp, nil
 }
 
-// GetFilterComplexity returns filter execution complexity metrics.
-func (c *ClickHouseClient) GetFilterComplexity(ctx context.Context, tenantID, jobID string) (*domain.FilterComplexityResponse, error) {
+// maxMostExecutedFilters caps the most executed filters listed.
+const maxMostExecutedFilters = 50
+
+// GetFilterComplexity returns filter execution complexity metrics and the
+// share of API time spent in filters, for the job and by form. The
+// per-transaction breakdown, which groups every filter execution by trace,
+// is only read with detail.
+func (c *ClickHouseClient) GetFilterComplexity(ctx context.Context, tenantID, jobID string, detail bool) (*domain.FilterComplexityResponse, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	resp := &domain.FilterComplexityResponse{
 		MostExecuted:   []domain.MostExecutedFilter{},
 		PerTransaction: []domain.FilterPerTransaction{},
+		FormTimeShare:  []domain.FilterTimeShare{},
 	}
-
-	// Most executed filters
-	meRows, err := c.conn.Query(ctx, `
-		SELECT
-			filter_name AS name,
-			count() AS cnt,
-			toInt64(sum(duration_ms)) AS total_ms
-		FROM log_entries
-		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = 'FLTR' AND filter_name != ''
-		GROUP BY filter_name
-		ORDER BY cnt DESC
-		LIMIT 50
-	`,
+	args := []any{
 		clickhouse.Named("tenantID", tenantID),
 		clickhouse.Named("jobID", jobID),
+	}
+
+	// Filters by name and the job's filter and API time in one pass; API
+	// entries have no filter name and only count towards the API time.
+	var (
+		filterMS, apiMS int64
+		names           []string
+		counts, totals  []int64
 	)
+	rows, err := c.conn.Query(ctx, `
+		SELECT
+			toInt64(sum(fltr_ms)) AS filter_ms,
+			toInt64(sum(api_ms)) AS api_ms,
+			groupArrayIf(name, name != '' AND cnt > 0) AS names,
+			groupArrayIf(cnt, name != '' AND cnt > 0) AS counts,
+			groupArrayIf(fltr_ms, name != '' AND cnt > 0) AS totals
+		FROM (
+			SELECT
+				filter_name AS name,
+				toInt64(countIf(log_type = 'FLTR')) AS cnt,
+				toInt64(sumIf(duration_ms, log_type = 'FLTR')) AS fltr_ms,
+				toInt64(sumIf(duration_ms, log_type = 'API')) AS api_ms
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type IN ('API', 'FLTR')
+			GROUP BY filter_name
+		)
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("clickhouse: most executed filters: %w", err)
+		return nil, fmt.Errorf("clickhouse: filter totals: %w", err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err := rows.Scan(&filterMS, &apiMS, &names, &counts, &totals); err != nil {
+			return nil, fmt.Errorf("clickhouse: filter totals scan: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: filter totals rows: %w", err)
+	}
+	for i, name := range names {
+		resp.MostExecuted = append(resp.MostExecuted, domain.MostExecutedFilter{Name: name, Count: counts[i], TotalMS: totals[i]})
 	}
-	defer meRows.Close()
+	sort.SliceStable(resp.MostExecuted, func(i, j int) bool {
+		a, b := resp.MostExecuted[i], resp.MostExecuted[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	if len(resp.MostExecuted) > maxMostExecutedFilters {
+		resp.MostExecuted = resp.MostExecuted[:maxMostExecutedFilters]
+	}
+	resp.TotalFilterTimeMS = filterMS
+	share := domain.NewFilterTimeShare("", filterMS, apiMS)
+	resp.TimeShare = &share
 
-	for meRows.Next() {
-		var f domain.MostExecutedFilter
-		if err := meRows.Scan(&f.Name, &f.Count, &f.TotalMS); err != nil {
-			return nil, fmt.Errorf("clickhouse: most executed filters scan: %w", err)
+	// Time share by form
+	formRows, err := c.conn.Query(ctx, `
+		SELECT
+			form,
+			toInt64(sumIf(duration_ms, log_type = 'FLTR')) AS filter_ms,
+			toInt64(sumIf(duration_ms, log_type = 'API')) AS api_ms
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type IN ('API', 'FLTR') AND form != ''
+		GROUP BY form
+		HAVING filter_ms > 0
+		ORDER BY filter_ms DESC, form
+		LIMIT 50
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: filter time share: %w", err)
+	}
+	defer formRows.Close()
+	for formRows.Next() {
+		var (
+			form     string
+			fms, ams int64
+		)
+		if err := formRows.Scan(&form, &fms, &ams); err != nil {
+			return nil, fmt.Errorf("clickhouse: filter time share scan: %w", err)
 		}
-		resp.MostExecuted = append(resp.MostExecuted, f)
+		resp.FormTimeShare = append(resp.FormTimeShare, domain.NewFilterTimeShare(form, fms, ams))
+	}
+	if err := formRows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: filter time share rows: %w", err)
 	}
-	if err := meRows.Err(); err != nil {
-		return nil, fmt.Errorf("clickhouse: most executed filters rows: %w", err)
+
+	if !detail {
+		return resp, nil
 	}
 
 	// Per transaction
@@ -1240,10 +2017,7 @@ func (c *ClickHouseClient) GetFilterComplexity(ctx context.Context, tenantID, jo
 		GROUP BY trace_id, filter_name
 		ORDER BY total_ms DESC
 		LIMIT 100
-	`,
-		clickhouse.Named("tenantID", tenantID),
-		clickhouse.Named("jobID", jobID),
-	)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("clickhouse: filter per transaction: %w", err)
 	}
@@ -1263,42 +2037,101 @@ func (c *ClickHouseClient) GetFilterComplexity(ctx context.Context, tenantID, jo
 		return nil, fmt.Errorf("clickhouse: filter per transaction rows: %w", err)
 	}
 
-	// Total filter time
-	var totalMS int64
-	row := c.conn.QueryRow(ctx, `
-		SELECT toInt64(sum(duration_ms))
-		FROM log_entries
-		WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = 'FLTR'
-	`,
+	return resp, nil
+}
+
+// thresholdScopeColumns maps rule scopes to the log_entries column they filter.
+var thresholdScopeColumns = map[domain.ThresholdScope]string{
+	domain.ThresholdScopeForm:    "form",
+	domain.ThresholdScopeQueue:   "queue",
+	domain.ThresholdScopeLogType: "log_type",
+}
+
+// GetThresholdMetrics computes the metrics threshold rules compare against,
+// restricted to the entries matching scope/scopeValue. Error rate is a
+// percentage; durations and gaps are in milliseconds.
+func (c *ClickHouseClient) GetThresholdMetrics(ctx context.Context, tenantID, jobID string, scope domain.ThresholdScope, scopeValue string) (*domain.ThresholdMetrics, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	scopeFilter := ""
+	if scope != domain.ThresholdScopeGlobal {
+		col, ok := thresholdScopeColumns[scope]
+		if !ok {
+			return nil, fmt.Errorf("clickhouse: unsupported threshold scope %q", scope)
+		}
+		scopeFilter = fmt.Sprintf(" AND %s = @scopeValue", col)
+	}
+	args := []any{
 		clickhouse.Named("tenantID", tenantID),
 		clickhouse.Named("jobID", jobID),
-	)
-	if err := row.Scan(&totalMS); err != nil {
-		return nil, fmt.Errorf("clickhouse: filter total time: %w", err)
+		clickhouse.Named("scopeValue", scopeValue),
 	}
-	resp.TotalFilterTimeMS = totalMS
 
-	return resp, nil
+	var m domain.ThresholdMetrics
+	var count uint64
+	row := c.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+			sum(sample_weight) AS entries,
+			if(count() > 0, sumIf(sample_weight, success = false) * 100 / sum(sample_weight), 0) AS error_rate,
+			if(count() > 0, toFloat64(quantileExactWeighted(0.95)(duration_ms, sample_weight)), 0) AS p95_ms,
+			if(count() > 0, avgWeighted(duration_ms, sample_weight), 0) AS avg_ms
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID%s
+	`, scopeFilter), args...)
+	if err := row.Scan(&count, &m.ErrorRate, &m.P95MS, &m.AvgMS); err != nil {
+		return nil, fmt.Errorf("clickhouse: threshold metrics: %w", err)
+	}
+	m.EntryCount = int64(count)
+
+	var maxGapMS int64
+	gapRow := c.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT if(count() > 0, max(gap_ms), 0)
+		FROM (
+			SELECT dateDiff('millisecond', timestamp, neighbor(timestamp, 1)) AS gap_ms
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID%s
+			ORDER BY timestamp ASC
+		)
+		WHERE gap_ms > 0
+	`, scopeFilter), args...)
+	if err := gapRow.Scan(&maxGapMS); err != nil {
+		return nil, fmt.Errorf("clickhouse: threshold metrics gaps: %w", err)
+	}
+	m.MaxGapMS = float64(maxGapMS)
+
+	return &m, nil
 }
 
-// ComputeHealthScore calculates a composite health score (0-100) from 4 weighted factors.
-func (c *ClickHouseClient) ComputeHealthScore(ctx context.Context, tenantID, jobID string) (*domain.HealthScore, error) {
+// ComputeHealthScore calculates a composite health score (0-100) from the
+// weighted factors of profile. A nil profile means DefaultHealthProfile.
+// Gaps that are the downtime of one of restarts do not count against the
+// gap factor. The response time factor averages the calls timed above
+// zero, leaving out those within the restarts' warm-up windows.
+func (c *ClickHouseClient) ComputeHealthScore(ctx context.Context, tenantID, jobID string, profile *domain.HealthProfile, restarts []domain.RestartEvent) (*domain.HealthScore, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	warmupFilter, warmupArgs, warmups := restartWarmupFilter(restarts)
+
 	// Fetch metrics in a single query
+	args := append([]any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	}, warmupArgs...)
 	row := c.conn.QueryRow(ctx, `
 		SELECT
-			if(count() > 0, countIf(success = false) / count(), 0) AS error_rate,
-			avg(duration_ms) AS avg_duration_ms
+			if(count() > 0, sumIf(sample_weight, success = false) / sum(sample_weight), 0) AS error_rate,
+			avgWeightedIf(duration_ms, sample_weight, duration_ms > 0 AND `+warmupFilter+`) AS avg_duration_ms
 		FROM log_entries
 		WHERE tenant_id = @tenantID AND job_id = @jobID
-	`,
-		clickhouse.Named("tenantID", tenantID),
-		clickhouse.Named("jobID", jobID),
-	)
+	`, args...)
 
 	var errorRate, avgDuration float64
 	if err := row.Scan(&errorRate, &avgDuration); err != nil {
 		return nil, fmt.Errorf("clickhouse: health score base metrics: %w", err)
 	}
+	if math.IsNaN(avgDuration) {
+		avgDuration = 0
+	}
 
 	// Max thread busy pct
 	var maxBusyPct float64
@@ -1311,7 +2144,7 @@ func (c *ClickHouseClient) ComputeHealthScore(ctx context.Context, tenantID, job
 			SELECT
 				if(
 					dateDiff('millisecond', min(timestamp), max(timestamp)) > 0,
-					least((sum(duration_ms) / dateDiff('millisecond', min(timestamp), max(timestamp))) * 100, 100),
+					least((sum(duration_ms * sample_weight) / dateDiff('millisecond', min(timestamp), max(timestamp))) * 100, 100),
 					0
 				) AS busy_pct
 			FROM log_entries
@@ -1326,158 +2159,92 @@ func (c *ClickHouseClient) ComputeHealthScore(ctx context.Context, tenantID, job
 		return nil, fmt.Errorf("clickhouse: health score busy pct: %w", err)
 	}
 
-	// Max gap duration
-	var maxGapMS int64
-	gapRow := c.conn.QueryRow(ctx, `
-		SELECT if(count() > 0, max(gap_ms), 0)
+	// Longest gap that is not a restart's downtime: each restart explains
+	// at most one gap, so one more gap than restarts is enough.
+	gapRows, err := c.conn.Query(ctx, `
+		SELECT start_time, end_time, gap_ms
 		FROM (
-			SELECT dateDiff('millisecond', timestamp, neighbor(timestamp, 1)) AS gap_ms
+			SELECT
+				timestamp AS start_time,
+				neighbor(timestamp, 1) AS end_time,
+				dateDiff('millisecond', timestamp, neighbor(timestamp, 1)) AS gap_ms
 			FROM log_entries
 			WHERE tenant_id = @tenantID AND job_id = @jobID
 			ORDER BY timestamp ASC
 		)
 		WHERE gap_ms > 0
+		ORDER BY gap_ms DESC
+		LIMIT @limit
 	`,
 		clickhouse.Named("tenantID", tenantID),
 		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("limit", len(restarts)+1),
 	)
-	if err := gapRow.Scan(&maxGapMS); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("clickhouse: health score gaps: %w", err)
 	}
+	defer gapRows.Close()
 
-	// Compute factor scores
-	factors := make([]domain.HealthScoreFactor, 4)
-
-	// Error Rate (weight: 0.30)
-	errScore := scoreErrorRate(errorRate)
-	factors[0] = domain.HealthScoreFactor{
-		Name:        "Error Rate",
-		Score:       errScore,
-		MaxScore:    100,
-		Weight:      0.30,
-		Description: fmt.Sprintf("%.2f%% of operations failed", errorRate*100),
-		Severity:    scoreSeverity(errScore),
-	}
-
-	// Avg Response Time (weight: 0.25)
-	rtScore := scoreResponseTime(avgDuration)
-	factors[1] = domain.HealthScoreFactor{
-		Name:        "Avg Response Time",
-		Score:       rtScore,
-		MaxScore:    100,
-		Weight:      0.25,
-		Description: fmt.Sprintf("%.0fms average duration", avgDuration),
-		Severity:    scoreSeverity(rtScore),
-	}
-
-	// Thread Saturation (weight: 0.25)
-	tsScore := scoreThreadSaturation(maxBusyPct)
-	factors[2] = domain.HealthScoreFactor{
-		Name:        "Thread Saturation",
-		Score:       tsScore,
-		MaxScore:    100,
-		Weight:      0.25,
-		Description: fmt.Sprintf("%.0f%% max thread utilization", maxBusyPct),
-		Severity:    scoreSeverity(tsScore),
-	}
-
-	// Gap Frequency (weight: 0.20)
-	gapSecs := float64(maxGapMS) / 1000.0
-	gfScore := scoreGapFrequency(gapSecs)
-	factors[3] = domain.HealthScoreFactor{
-		Name:        "Gap Frequency",
-		Score:       gfScore,
-		MaxScore:    100,
-		Weight:      0.20,
-		Description: fmt.Sprintf("%.1fs longest gap", gapSecs),
-		Severity:    scoreSeverity(gfScore),
-	}
-
-	// Weighted composite
-	composite := float64(errScore)*0.30 + float64(rtScore)*0.25 + float64(tsScore)*0.25 + float64(gfScore)*0.20
-	score := int(math.Round(composite))
-
-	status := "green"
-	if score < 50 {
-		status = "red"
-	} else if score <= 80 {
-		status = "yellow"
-	}
-
-	return &domain.HealthScore{
-		Score:   score,
-		Status:  status,
-		Factors: factors,
-	}, nil
+	var maxGapMS int64
+	restartGaps := 0
+	for gapRows.Next() {
+		var start, end time.Time
+		var gapMS int64
+		if err := gapRows.Scan(&start, &end, &gapMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: health score gaps scan: %w", err)
+		}
+		if restartExplainsGap(restarts, start, end) != nil {
+			restartGaps++
+			continue
+		}
+		maxGapMS = gapMS
+		break
+	}
+	if err := gapRows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: health score gaps rows: %w", err)
+	}
+
+	if profile == nil {
+		profile = DefaultHealthProfile()
+	}
+	return scoreHealth(healthMetrics{
+		errorRate:     errorRate,
+		avgDurationMS: avgDuration,
+		maxBusyPct:    maxBusyPct,
+		maxGapSeconds: float64(maxGapMS) / 1000.0,
+		restartGaps:   restartGaps,
+		warmupWindows: warmups,
+	}, profile), nil
 }
 
-func scoreErrorRate(rate float64) int {
-	switch {
-	case rate < 0.01:
-		return 100
-	case rate < 0.02:
-		return 80
-	case rate < 0.05:
-		return 50
-	case rate < 0.10:
-		return 25
-	default:
-		return 0
+// defaultFactorScore scores a metric with the default profile's breakpoints.
+func defaultFactorScore(name domain.HealthFactorName, value float64) int {
+	for _, f := range DefaultHealthProfile().Factors {
+		if f.Name == name {
+			return scoreFactor(f, value)
+		}
 	}
+	return 0
+}
+
+func scoreErrorRate(rate float64) int {
+	return defaultFactorScore(domain.HealthFactorErrorRate, rate)
 }
 
 func scoreResponseTime(avgMS float64) int {
-	switch {
-	case avgMS < 500:
-		return 100
-	case avgMS < 1000:
-		return 80
-	case avgMS < 2000:
-		return 50
-	case avgMS < 5000:
-		return 25
-	default:
-		return 0
-	}
+	return defaultFactorScore(domain.HealthFactorResponseTime, avgMS)
 }
 
 func scoreThreadSaturation(maxBusyPct float64) int {
-	switch {
-	case maxBusyPct < 50:
-		return 100
-	case maxBusyPct < 70:
-		return 80
-	case maxBusyPct < 85:
-		return 50
-	case maxBusyPct < 95:
-		return 25
-	default:
-		return 0
-	}
+	return defaultFactorScore(domain.HealthFactorThreadSaturation, maxBusyPct)
 }
 
 func scoreGapFrequency(maxGapSecs float64) int {
-	switch {
-	case maxGapSecs < 5:
-		return 100
-	case maxGapSecs < 15:
-		return 80
-	case maxGapSecs < 30:
-		return 50
-	case maxGapSecs < 60:
-		return 25
-	default:
-		return 0
-	}
+	return defaultFactorScore(domain.HealthFactorGapFrequency, maxGapSecs)
 }
 
 func scoreSeverity(score int) string {
-	if score > 80 {
-		return "green"
-	} else if score >= 50 {
-		return "yellow"
-	}
-	return "red"
+	return healthStatus(score, DefaultHealthProfile())
 }
 
 func computeBucketSize(rangeStart, rangeEnd time.Time) string {
@@ -1505,6 +2272,9 @@ func computeBucketSize(rangeStart, rangeEnd time.Time) string {
 }
 
 func (c *ClickHouseClient) GetHistogramData(ctx context.Context, tenantID, jobID string, timeFrom, timeTo time.Time) (*domain.HistogramResponse, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	ctx = c.cacheReads(ctx, tenantID, jobID)
 	bucketSize := computeBucketSize(timeFrom, timeTo)
 
 	// INTERVAL cannot be passed as a named parameter; interpolate directly.
@@ -1514,7 +2284,7 @@ func (c *ClickHouseClient) GetHistogramData(ctx context.Context, tenantID, jobID
 		SELECT
 			toStartOfInterval(timestamp, INTERVAL %s) AS bucket,
 			toString(log_type) AS lt,
-			count() AS cnt
+			sum(sample_weight) AS cnt
 		FROM log_entries
 		WHERE tenant_id = {tenant_id:String}
 		  AND job_id = {job_id:String}
@@ -1523,6 +2293,23 @@ func (c *ClickHouseClient) GetHistogramData(ctx context.Context, tenantID, jobID
 		GROUP BY bucket, lt
 		ORDER BY bucket ASC
 	`, bucketSize)
+	// Buckets of a minute or more are summed from the rollup, whose
+	// minutes widen the range to whole minutes.
+	if intervalDuration(bucketSize) >= time.Minute && c.hasRollup(ctx, tenantID, jobID) {
+		query = fmt.Sprintf(`
+			SELECT
+				toStartOfInterval(minute, INTERVAL %s) AS bucket,
+				toString(log_type) AS lt,
+				sum(entries) AS cnt
+			FROM `+rollupTable+`
+			WHERE tenant_id = {tenant_id:String}
+			  AND job_id = {job_id:String}
+			  AND minute >= toStartOfMinute(toDateTime64({time_from:String}, 3))
+			  AND minute <= toDateTime64({time_to:String}, 3)
+			GROUP BY bucket, lt
+			ORDER BY bucket ASC
+		`, bucketSize)
+	}
 
 	rows, err := c.conn.Query(ctx, query,
 		clickhouse.Named("tenant_id", tenantID),
@@ -1545,7 +2332,7 @@ func (c *ClickHouseClient) GetHistogramData(ctx context.Context, tenantID, jobID
 		}
 
 		if _, ok := bucketMap[bucket]; !ok {
-			bucketMap[bucket] = &domain.HistogramBucket{Timestamp: bucket}
+			bucketMap[bucket] = &domain.HistogramBucket{Timestamp: domain.NewTimestamp(bucket)}
 		}
 
 		c := int64(cnt)
@@ -1572,16 +2359,23 @@ func (c *ClickHouseClient) GetHistogramData(ctx context.Context, tenantID, jobID
 	}
 
 	sort.Slice(buckets, func(i, j int) bool {
-		return buckets[i].Timestamp.Before(buckets[j].Timestamp)
+		return buckets[i].Timestamp.Before(buckets[j].Timestamp.Time)
 	})
 
+	est, err := c.sampleEstimate(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: histogram: %w", err)
+	}
+
 	return &domain.HistogramResponse{
 		Buckets:    buckets,
 		BucketSize: bucketSize,
+		Estimate:   est,
 	}, nil
 }
 
 func (c *ClickHouseClient) GetEntryContext(ctx context.Context, tenantID, jobID, entryID string, window int) (*domain.ContextResponse, error) {
+	ctx = replicaRead(ctx)
 	if window <= 0 {
 		window = 10
 	}
@@ -1617,11 +2411,11 @@ func (c *ClickHouseClient) GetEntryContext(ctx context.Context, tenantID, jobID,
 			trace_id, rpc_id, thread_id,
 			queue, user,
 			duration_ms, queue_time_ms, success,
-			api_code, form,
+			api_code, form, client, client_ip,
 			sql_table, sql_statement,
 			filter_name, filter_level, operation, request_id,
 			esc_name, esc_pool, scheduled_time, delay_ms, error_encountered,
-			raw_text, error_message
+			raw_text, error_message, raw_text_truncated
 		FROM log_entries
 		WHERE tenant_id = {tenant_id:String}
 		  AND job_id = {job_id:String}
@@ -1651,17 +2445,17 @@ func (c *ClickHouseClient) GetEntryContext(ctx context.Context, tenantID, jobID,
 			&e.TraceID, &e.RPCID, &e.ThreadID,
 			&e.Queue, &e.User,
 			&e.DurationMS, &e.QueueTimeMS, &e.Success,
-			&e.APICode, &e.Form,
+			&e.APICode, &e.Form, &e.Client, &e.ClientIP,
 			&e.SQLTable, &e.SQLStatement,
 			&e.FilterName, &e.FilterLevel, &e.Operation, &e.RequestID,
 			&e.EscName, &e.EscPool, &scheduledTime, &e.DelayMS, &e.ErrorEncountered,
-			&e.RawText, &e.ErrorMessage,
+			&e.RawText, &e.ErrorMessage, &e.RawTextTruncated,
 		); err != nil {
 			return nil, fmt.Errorf("clickhouse: get entry context scan: %w", err)
 		}
 		e.LogType = domain.LogType(logType)
 		if !scheduledTime.IsZero() {
-			e.ScheduledTime = &scheduledTime
+			e.ScheduledTime = domain.TimestampPtr(&scheduledTime)
 		}
 
 		if e.EntryID == entryID {
@@ -1689,54 +2483,23 @@ func (c *ClickHouseClient) GetEntryContext(ctx context.Context, tenantID, jobID,
 	}, nil
 }
 
-// GetFacets returns facet counts for log_type, user, and queue columns,
+// GetFacets returns facet counts for log_type, user, queue and client_ip columns,
 // applying the same KQL-based WHERE clause as SearchEntries so facets reflect
-// the current search context.
+// the current search context. Results of complete jobs are cached. A facet
+// whose query fails is left out; ErrSearchTooBroad is returned when every
+// facet was stopped at the search limits.
 func (c *ClickHouseClient) GetFacets(ctx context.Context, tenantID, jobID string, q SearchQuery) (map[string][]FacetValue, error) {
-	where := "tenant_id = @tenantID AND job_id = @jobID"
-	namedArgs := []driver.NamedValue{
-		{Name: "tenantID", Value: tenantID},
-		{Name: "jobID", Value: jobID},
-	}
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	ctx = c.limitSearch(ctx)
+	ctx = c.cacheReads(ctx, tenantID, jobID)
+	where, chArgs := searchWhere(tenantID, jobID, q)
 
-	if q.Query != "" && q.Query != "*" {
-		parsed, parseErr := search.ParseKQL(q.Query)
-		if parseErr == nil && parsed != nil {
-			kqlSQL, kqlParams := parsed.ToClickHouseWhere()
-			paramIdx := 0
-			var converted strings.Builder
-			for _, ch := range kqlSQL {
-				if ch == '?' && paramIdx < len(kqlParams) {
-					paramName := fmt.Sprintf("kql_%d", paramIdx)
-					converted.WriteString("@" + paramName)
-					namedArgs = append(namedArgs, driver.NamedValue{Name: paramName, Value: kqlParams[paramIdx]})
-					paramIdx++
-				} else {
-					converted.WriteRune(ch)
-				}
-			}
-			where += " AND (" + converted.String() + ")"
-		}
-	}
-
-	if q.TimeFrom != nil {
-		where += " AND timestamp >= @timeFrom"
-		namedArgs = append(namedArgs, driver.NamedValue{Name: "timeFrom", Value: *q.TimeFrom})
-	}
-	if q.TimeTo != nil {
-		where += " AND timestamp <= @timeTo"
-		namedArgs = append(namedArgs, driver.NamedValue{Name: "timeTo", Value: *q.TimeTo})
-	}
-
-	chArgs := make([]any, len(namedArgs))
-	for i, na := range namedArgs {
-		chArgs[i] = clickhouse.Named(na.Name, na.Value)
-	}
-
-	facetFields := []string{"log_type", "user", "queue"}
+	facetFields := []string{"log_type", "user", "queue", "client_ip"}
 	// Enum/Bool columns cannot be compared with != '' — skip the empty filter for them.
 	enumFields := map[string]bool{"log_type": true, "success": true}
 	result := make(map[string][]FacetValue)
+	var tooBroad error
 
 	for _, field := range facetFields {
 		emptyFilter := fmt.Sprintf("AND %s != ''", field)
@@ -1754,6 +2517,10 @@ func (c *ClickHouseClient) GetFacets(ctx context.Context, tenantID, jobID string
 
 		rows, err := c.conn.Query(ctx, query, chArgs...)
 		if err != nil {
+			err = searchError("facet query", err)
+			if errors.Is(err, ErrSearchTooBroad) {
+				tooBroad = err
+			}
 			slog.Warn("facet query failed", "field", field, "error", err, "query", query)
 			continue // non-fatal: skip this facet
 		}
@@ -1775,9 +2542,82 @@ func (c *ClickHouseClient) GetFacets(ctx context.Context, tenantID, jobID string
 		}
 	}
 
+	if len(result) == 0 && tooBroad != nil {
+		return nil, tooBroad
+	}
 	return result, nil
 }
 
+// RefinementStats counts the entries of a search that duration and
+// failure refinements would keep.
+type RefinementStats struct {
+	Failed     int64           `json:"failed"`
+	SlowerThan []DurationCount `json:"slower_than,omitempty"` // by ascending threshold
+}
+
+// DurationCount is the number of entries slower than ThresholdMS.
+type DurationCount struct {
+	ThresholdMS int64 `json:"threshold_ms"`
+	Count       int64 `json:"count"`
+}
+
+// GetRefinementStats runs the two queries behind the duration and failure
+// refinements of a search: the p90 and p99 durations and the failed count
+// of its entries, then the number of entries slower than each of the two
+// quantiles rounded down to one significant digit.
+func (c *ClickHouseClient) GetRefinementStats(ctx context.Context, tenantID, jobID string, q SearchQuery) (*RefinementStats, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	where, chArgs := searchWhere(tenantID, jobID, q)
+
+	var quantiles []float64
+	var failed uint64
+	query := fmt.Sprintf("SELECT quantiles(0.9, 0.99)(duration_ms), countIf(NOT success) FROM log_entries WHERE %s", where)
+	if err := c.conn.QueryRow(ctx, query, chArgs...).Scan(&quantiles, &failed); err != nil {
+		return nil, fmt.Errorf("clickhouse: refinement quantiles: %w", err)
+	}
+	stats := &RefinementStats{Failed: int64(failed)}
+
+	var thresholds []int64
+	for _, v := range quantiles {
+		t := roundDownSignificant(v)
+		if t > 0 && (len(thresholds) == 0 || t > thresholds[len(thresholds)-1]) {
+			thresholds = append(thresholds, t)
+		}
+	}
+	if len(thresholds) == 0 {
+		return stats, nil
+	}
+
+	cols := make([]string, len(thresholds))
+	for i, t := range thresholds {
+		cols[i] = fmt.Sprintf("countIf(duration_ms > %d)", t)
+	}
+	counts := make([]uint64, len(thresholds))
+	dest := make([]any, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	query = fmt.Sprintf("SELECT %s FROM log_entries WHERE %s", strings.Join(cols, ", "), where)
+	if err := c.conn.QueryRow(ctx, query, chArgs...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("clickhouse: refinement durations: %w", err)
+	}
+	for i, t := range thresholds {
+		stats.SlowerThan = append(stats.SlowerThan, DurationCount{ThresholdMS: t, Count: int64(counts[i])})
+	}
+	return stats, nil
+}
+
+// roundDownSignificant rounds v down to one significant digit: 5437 to
+// 5000, 87 to 80. Values below 1, and NaN, are 0.
+func roundDownSignificant(v float64) int64 {
+	if !(v >= 1) {
+		return 0
+	}
+	scale := math.Pow(10, math.Floor(math.Log10(v)))
+	return int64(math.Floor(v/scale) * scale)
+}
+
 var knownFields = map[string]bool{
 	"log_type":          true,
 	"user":              true,
@@ -1787,6 +2627,8 @@ var knownFields = map[string]bool{
 	"rpc_id":            true,
 	"api_code":          true,
 	"form":              true,
+	"client":            true,
+	"client_ip":         true,
 	"operation":         true,
 	"request_id":        true,
 	"sql_table":         true,
@@ -1803,6 +2645,7 @@ func IsKnownField(field string) bool {
 }
 
 func (c *ClickHouseClient) GetAutocompleteValues(ctx context.Context, tenantID, jobID, field, prefix string, limit int) ([]domain.AutocompleteValue, error) {
+	ctx = replicaRead(ctx)
 	if !IsKnownField(field) {
 		return nil, fmt.Errorf("clickhouse: unknown field for autocomplete: %s", field)
 	}
@@ -1812,6 +2655,7 @@ func (c *ClickHouseClient) GetAutocompleteValues(ctx context.Context, tenantID,
 	}
 
 	pattern := escapeLikePattern(prefix) + "%"
+	ctx = c.cacheReads(ctx, tenantID, jobID)
 
 	// Enum/Bool columns need toString() for LIKE and cannot use != ''
 	enumOrBoolFields := map[string]bool{"log_type": true, "success": true}
@@ -1873,6 +2717,8 @@ func (c *ClickHouseClient) GetAutocompleteValues(ctx context.Context, tenantID,
 
 // GetJobTimeRange returns the min and max timestamps for a job's log entries.
 func (c *ClickHouseClient) GetJobTimeRange(ctx context.Context, tenantID, jobID string) (*JobTimeRange, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	var minTS, maxTS time.Time
 	err := c.conn.QueryRow(ctx, `
 		SELECT min(timestamp), max(timestamp)
@@ -1886,14 +2732,179 @@ func (c *ClickHouseClient) GetJobTimeRange(ctx context.Context, tenantID, jobID
 		return nil, fmt.Errorf("clickhouse: get job time range: %w", err)
 	}
 
-	if minTS.IsZero() && maxTS.IsZero() {
-		return nil, fmt.Errorf("clickhouse: no entries found for job %s", jobID)
+	// min and max of no rows are the zero DateTime, 1970-01-01.
+	if (minTS.IsZero() || minTS.Unix() == 0) && (maxTS.IsZero() || maxTS.Unix() == 0) {
+		return nil, fmt.Errorf("clickhouse: job %s: %w", jobID, ErrNoJobEntries)
 	}
 
 	return &JobTimeRange{Start: minTS, End: maxTS}, nil
 }
 
+// CountJobEntries returns the number of log entries stored for a job.
+func (c *ClickHouseClient) CountJobEntries(ctx context.Context, tenantID, jobID string) (int64, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	var count uint64
+	err := c.conn.QueryRow(ctx, `
+		SELECT count()
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("clickhouse: count job entries: %w", err)
+	}
+	return int64(count), nil
+}
+
+// CountJobEntriesByType returns the number of log entries stored for a
+// job per log type, counting sampled entries by their weight.
+func (c *ClickHouseClient) CountJobEntriesByType(ctx context.Context, tenantID, jobID string) (map[domain.LogType]int64, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	rows, err := c.conn.Query(ctx, `
+		SELECT toString(log_type) AS lt, sum(sample_weight) AS cnt
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+		GROUP BY lt
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: count job entries by type: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[domain.LogType]int64)
+	for rows.Next() {
+		var lt string
+		var cnt uint64
+		if err := rows.Scan(&lt, &cnt); err != nil {
+			return nil, fmt.Errorf("clickhouse: count job entries by type scan: %w", err)
+		}
+		counts[domain.LogType(lt)] = int64(cnt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: count job entries by type rows: %w", err)
+	}
+	return counts, nil
+}
+
+// GetSourceOverlay returns the entries of a job of a non-AR log source as
+// an overlay: per minute counts per log type and the maxEvents longest
+// entries, in time order. The log types of AR Server logs are left out.
+func (c *ClickHouseClient) GetSourceOverlay(ctx context.Context, tenantID, jobID string, maxEvents int) (*domain.SourceOverlay, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	if maxEvents <= 0 {
+		maxEvents = 100
+	}
+	overlay := &domain.SourceOverlay{Series: []domain.OverlayPoint{}, Events: []domain.OverlayEvent{}}
+
+	rows, err := c.conn.Query(ctx, `
+		SELECT
+			toStartOfMinute(timestamp)            AS ts,
+			toString(log_type)                    AS lt,
+			sum(sample_weight)                    AS cnt,
+			sumIf(sample_weight, success = false) AS errors,
+			max(duration_ms)                      AS max_ms
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+		  AND log_type NOT IN ('API', 'SQL', 'FLTR', 'ESCL')
+		GROUP BY ts, lt
+		ORDER BY ts, lt
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: source overlay series: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			p           domain.OverlayPoint
+			lt          string
+			cnt, errCnt uint64
+			maxMS       uint32
+		)
+		if err := rows.Scan(&p.Timestamp, &lt, &cnt, &errCnt, &maxMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: source overlay series scan: %w", err)
+		}
+		p.LogType = domain.LogType(lt)
+		p.Count, p.ErrorCount, p.MaxDurationMS = int64(cnt), int64(errCnt), int64(maxMS)
+		overlay.Series = append(overlay.Series, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: source overlay series rows: %w", err)
+	}
+
+	events, err := c.conn.Query(ctx, `
+		SELECT entry_id, timestamp, toString(log_type) AS lt, duration_ms,
+		       if(form = '', operation, concat(operation, ' ', form)) AS label, success
+		FROM (
+			SELECT entry_id, timestamp, log_type, duration_ms, operation, form, success
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID
+			  AND log_type NOT IN ('API', 'SQL', 'FLTR', 'ESCL')
+			ORDER BY duration_ms DESC
+			LIMIT @limit
+		)
+		ORDER BY timestamp
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("limit", maxEvents),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: source overlay events: %w", err)
+	}
+	defer events.Close()
+	for events.Next() {
+		var (
+			e          domain.OverlayEvent
+			lt         string
+			durationMS uint32
+		)
+		if err := events.Scan(&e.EntryID, &e.Timestamp, &lt, &durationMS, &e.Label, &e.Success); err != nil {
+			return nil, fmt.Errorf("clickhouse: source overlay events scan: %w", err)
+		}
+		e.LogType = domain.LogType(lt)
+		e.DurationMS = int64(durationMS)
+		overlay.Events = append(overlay.Events, e)
+	}
+	if err := events.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: source overlay events rows: %w", err)
+	}
+	return overlay, nil
+}
+
+// DeleteJobEntries deletes the log entries of a job, their minute
+// aggregates and its rollup. The deletes are mutations that ClickHouse
+// applies in the background.
+func (c *ClickHouseClient) DeleteJobEntries(ctx context.Context, tenantID, jobID string) error {
+	assertCanonicalIDs(tenantID, jobID)
+	c.forgetRollup(tenantID, jobID)
+	for _, table := range []string{"log_entries", "log_entries_aggregates", rollupTable} {
+		if err := c.conn.Exec(ctx, `
+			ALTER TABLE `+table+`
+			DELETE WHERE tenant_id = @tenantID AND job_id = @jobID
+		`,
+			clickhouse.Named("tenantID", tenantID),
+			clickhouse.Named("jobID", jobID),
+		); err != nil {
+			return fmt.Errorf("clickhouse: delete job entries from %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
 func (c *ClickHouseClient) SearchTransactions(ctx context.Context, tenantID, jobID string, params domain.TransactionSearchParams) (*domain.TransactionSearchResponse, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	start := time.Now()
 
 	if params.Limit <= 0 {
@@ -2012,8 +3023,8 @@ func (c *ClickHouseClient) SearchTransactions(ctx context.Context, tenantID, job
 		}
 		ts.SpanCount = int(spanCount)
 		ts.ErrorCount = int(errorCount)
-		ts.FirstTimestamp = firstTS.Format(time.RFC3339)
-		ts.LastTimestamp = lastTS.Format(time.RFC3339)
+		ts.FirstTimestamp = domain.NewTimestamp(firstTS)
+		ts.LastTimestamp = domain.NewTimestamp(lastTS)
 		transactions = append(transactions, ts)
 	}
 
@@ -2031,6 +3042,8 @@ func (c *ClickHouseClient) SearchTransactions(ctx context.Context, tenantID, job
 // QueryDelayedEscalations returns escalation entries whose delay_ms exceeds the
 // given threshold, ordered by delay descending.
 func (c *ClickHouseClient) QueryDelayedEscalations(ctx context.Context, tenantID, jobID string, minDelayMS int, limit int) ([]domain.DelayedEscalationEntry, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
 	if limit <= 0 || limit > 100 {
 		limit = 50
 	}
@@ -2078,7 +3091,7 @@ func (c *ClickHouseClient) QueryDelayedEscalations(ctx context.Context, tenantID
 			return nil, fmt.Errorf("clickhouse: scan delayed escalation: %w", err)
 		}
 		if !scheduledTime.IsZero() {
-			e.ScheduledTime = &scheduledTime
+			e.ScheduledTime = domain.TimestampPtr(&scheduledTime)
 		}
 		e.LineNumber = int64(lineNumber)
 		entries = append(entries, e)
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// maxSlowQueryText caps the text of a statement returned by
+// ListJobSlowQueries.
+const maxSlowQueryText = 2000
+
+// JobEntryStats returns the rows stored for a job per log type, with their
+// weighted count and the time range they span.
+func (c *ClickHouseClient) JobEntryStats(ctx context.Context, tenantID, jobID string) ([]domain.LogTypeStats, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	rows, err := c.conn.Query(ctx, `
+		SELECT
+			toString(log_type) AS lt,
+			count() AS row_count,
+			sum(sample_weight) AS entries,
+			min(timestamp) AS min_ts,
+			max(timestamp) AS max_ts
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+		GROUP BY lt
+		ORDER BY lt
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: job entry stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []domain.LogTypeStats{}
+	for rows.Next() {
+		var s domain.LogTypeStats
+		var lt string
+		var rowCount, entries uint64
+		if err := rows.Scan(&lt, &rowCount, &entries, &s.MinTime, &s.MaxTime); err != nil {
+			return nil, fmt.Errorf("clickhouse: job entry stats scan: %w", err)
+		}
+		s.LogType = domain.LogType(lt)
+		s.Rows = int64(rowCount)
+		s.Entries = int64(entries)
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: job entry stats rows: %w", err)
+	}
+	return stats, nil
+}
+
+// ListJobSlowQueries returns the slowest SELECT statements naming a job
+// that the server logged since since, taking at least minDuration, slowest
+// first. It reads system.query_log, which the server must have enabled.
+func (c *ClickHouseClient) ListJobSlowQueries(ctx context.Context, jobID string, since time.Time, minDuration time.Duration, limit int) ([]domain.SlowQuery, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := c.conn.Query(ctx, `
+		SELECT
+			query_start_time,
+			query_duration_ms,
+			read_rows,
+			memory_usage,
+			exception_code,
+			substring(query, 1, @maxText)
+		FROM system.query_log
+		WHERE event_date >= toDate(@since)
+		  AND query_start_time >= @since
+		  AND type IN ('QueryFinish', 'ExceptionWhileProcessing')
+		  AND query_kind = 'Select'
+		  AND query_duration_ms >= @minMS
+		  AND position(query, @jobID) > 0
+		ORDER BY query_duration_ms DESC
+		LIMIT @limit
+	`,
+		clickhouse.Named("maxText", maxSlowQueryText),
+		clickhouse.Named("since", since),
+		clickhouse.Named("minMS", minDuration.Milliseconds()),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: job slow queries: %w", err)
+	}
+	defer rows.Close()
+
+	queries := []domain.SlowQuery{}
+	for rows.Next() {
+		var q domain.SlowQuery
+		var durationMS, readRows, memory uint64
+		if err := rows.Scan(&q.StartedAt, &durationMS, &readRows, &memory, &q.ExceptionCode, &q.Query); err != nil {
+			return nil, fmt.Errorf("clickhouse: job slow queries scan: %w", err)
+		}
+		q.DurationMS = int64(durationMS)
+		q.ReadRows = int64(readRows)
+		q.MemoryBytes = int64(memory)
+		queries = append(queries, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: job slow queries rows: %w", err)
+	}
+	return queries, nil
+}
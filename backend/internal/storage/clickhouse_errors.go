@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// IsUnavailable reports whether err means ClickHouse could not be reached
+// or did not answer in time: a refused, reset or dropped connection, a
+// dial or read timeout, or no free connection in the pool. These pass with
+// the outage. Errors of the statement itself, reported by the server, are
+// not among them, and neither is the caller going away.
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || IsQueryError(err) {
+		return false
+	}
+	for _, target := range []error{
+		context.DeadlineExceeded,
+		clickhouse.ErrAcquireConnTimeout,
+		sqldriver.ErrBadConn,
+		net.ErrClosed,
+		io.EOF,
+		io.ErrUnexpectedEOF,
+		syscall.ECONNREFUSED,
+		syscall.ECONNRESET,
+		syscall.EPIPE,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsQueryError reports whether err is ClickHouse rejecting or failing a
+// statement it received: an exception from the server, or a search
+// stopped at the search limits. Retrying the statement fails the same way.
+func IsQueryError(err error) bool {
+	var ex *clickhouse.Exception
+	return errors.As(err, &ex) || errors.Is(err, ErrSearchTooBroad)
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	unavailable := []error{
+		fmt.Errorf("clickhouse: aggregates: %w", dial),
+		fmt.Errorf("clickhouse: rows: %w", syscall.ECONNRESET),
+		fmt.Errorf("clickhouse: ping: %w", context.DeadlineExceeded),
+		fmt.Errorf("clickhouse: query: %w", clickhouse.ErrAcquireConnTimeout),
+		fmt.Errorf("read: %w", io.EOF),
+		fmt.Errorf("read: %w", net.ErrClosed),
+	}
+	for _, err := range unavailable {
+		assert.True(t, IsUnavailable(err), "%v", err)
+		assert.False(t, IsQueryError(err), "%v", err)
+	}
+
+	available := []error{
+		nil,
+		context.Canceled,
+		fmt.Errorf("clickhouse: query: %w", &clickhouse.Exception{Code: 60, Message: "unknown table"}),
+		searchError("search count", &clickhouse.Exception{Code: 159, Message: "timeout exceeded"}),
+		errors.New("clickhouse: scan: converting NULL to string"),
+	}
+	for _, err := range available {
+		assert.False(t, IsUnavailable(err), "%v", err)
+	}
+}
+
+func TestIsQueryError(t *testing.T) {
+	assert.True(t, IsQueryError(fmt.Errorf("clickhouse: query: %w", &clickhouse.Exception{Code: 62})))
+	assert.True(t, IsQueryError(fmt.Errorf("clickhouse: search: %w", ErrSearchTooBroad)))
+	assert.False(t, IsQueryError(nil))
+	assert.False(t, IsQueryError(syscall.ECONNREFUSED))
+}
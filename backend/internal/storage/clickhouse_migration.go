@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// MigratedTables are the tables with per-job rows that a tenant migration
+// copies between clusters. The minute aggregates of log_entries are a
+// materialized view, which the inserts rebuild on the target; the minute
+// rollup is built on the target once the entries are copied.
+var MigratedTables = []string{"log_entries"}
+
+// ChunkChecksum is the row count of a chunk of a job and the sum of the
+// hashes of its rows, wrapping at 2^64. Equal checksums on two clusters
+// mean, short of a hash collision, that they hold the same rows.
+type ChunkChecksum struct {
+	Rows uint64
+	Sum  uint64
+}
+
+// RowChunk holds the rows of a chunk of a job, read to be copied to
+// another cluster.
+type RowChunk struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// chunkWhere selects the rows of a job with line numbers in [start, end).
+const chunkWhere = `tenant_id = @tenantID AND job_id = @jobID AND line_number >= @start AND line_number < @end`
+
+func chunkArgs(tenantID, jobID string, start, end int64) []any {
+	return []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("start", start),
+		clickhouse.Named("end", end),
+	}
+}
+
+// migratedTable checks that table is one of MigratedTables, as table names
+// are interpolated into the statements.
+func migratedTable(table string) error {
+	for _, t := range MigratedTables {
+		if t == table {
+			return nil
+		}
+	}
+	return fmt.Errorf("clickhouse: %q is not a migrated table", table)
+}
+
+// ListTenantJobIDs returns the IDs of the jobs of a tenant with rows in
+// table, in order.
+func (c *ClickHouseClient) ListTenantJobIDs(ctx context.Context, table, tenantID string) ([]string, error) {
+	if err := migratedTable(table); err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(ctx, `
+		SELECT DISTINCT job_id
+		FROM `+table+`
+		WHERE tenant_id = @tenantID
+		ORDER BY job_id
+	`, clickhouse.Named("tenantID", tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: list tenant jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan tenant job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// JobLineSpan returns the first and last line numbers of the rows of a job
+// in table. ok is false when the job has none.
+func (c *ClickHouseClient) JobLineSpan(ctx context.Context, table, tenantID, jobID string) (first, last int64, ok bool, err error) {
+	if err := migratedTable(table); err != nil {
+		return 0, 0, false, err
+	}
+	var lo, hi uint32
+	var n uint64
+	err = c.conn.QueryRow(ctx, `
+		SELECT min(line_number), max(line_number), count()
+		FROM `+table+`
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	).Scan(&lo, &hi, &n)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("clickhouse: job line span: %w", err)
+	}
+	return int64(lo), int64(hi), n > 0, nil
+}
+
+// ChunkChecksum returns the checksum of the rows of a job in table with
+// line numbers in [start, end).
+func (c *ClickHouseClient) ChunkChecksum(ctx context.Context, table, tenantID, jobID string, start, end int64) (ChunkChecksum, error) {
+	if err := migratedTable(table); err != nil {
+		return ChunkChecksum{}, err
+	}
+	var sum ChunkChecksum
+	err := c.conn.QueryRow(ctx, `
+		SELECT count(), sum(cityHash64(*))
+		FROM `+table+`
+		WHERE `+chunkWhere,
+		chunkArgs(tenantID, jobID, start, end)...,
+	).Scan(&sum.Rows, &sum.Sum)
+	if err != nil {
+		return ChunkChecksum{}, fmt.Errorf("clickhouse: chunk checksum: %w", err)
+	}
+	return sum, nil
+}
+
+// ReadChunk reads every column of the rows of a job in table with line
+// numbers in [start, end).
+func (c *ClickHouseClient) ReadChunk(ctx context.Context, table, tenantID, jobID string, start, end int64) (*RowChunk, error) {
+	if err := migratedTable(table); err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(ctx, `SELECT * FROM `+table+` WHERE `+chunkWhere, chunkArgs(tenantID, jobID, start, end)...)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: read chunk: %w", err)
+	}
+	defer rows.Close()
+
+	types := rows.ColumnTypes()
+	chunk := &RowChunk{Columns: rows.Columns()}
+	for rows.Next() {
+		dest := make([]any, len(types))
+		for i, t := range types {
+			dest[i] = reflect.New(t.ScanType()).Interface()
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan chunk row: %w", err)
+		}
+		row := make([]any, len(dest))
+		for i, d := range dest {
+			row[i] = reflect.ValueOf(d).Elem().Interface()
+		}
+		chunk.Rows = append(chunk.Rows, row)
+	}
+	return chunk, rows.Err()
+}
+
+// InsertChunk inserts the rows of a chunk read by ReadChunk into table.
+func (c *ClickHouseClient) InsertChunk(ctx context.Context, table string, chunk *RowChunk) error {
+	if err := migratedTable(table); err != nil {
+		return err
+	}
+	if len(chunk.Rows) == 0 {
+		return nil
+	}
+	cols := make([]string, len(chunk.Columns))
+	for i, col := range chunk.Columns {
+		cols[i] = "`" + strings.ReplaceAll(col, "`", "``") + "`"
+	}
+	batch, err := c.conn.PrepareBatch(ctx, "INSERT INTO "+table+" ("+strings.Join(cols, ", ")+")")
+	if err != nil {
+		return fmt.Errorf("clickhouse: prepare chunk insert: %w", err)
+	}
+	for _, row := range chunk.Rows {
+		if err := batch.Append(row...); err != nil {
+			_ = batch.Abort()
+			return fmt.Errorf("clickhouse: append chunk row: %w", err)
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("clickhouse: send chunk: %w", err)
+	}
+	return nil
+}
+
+// DeleteChunk deletes the rows of a job in table with line numbers in
+// [start, end), such as those of a copy that was interrupted. Unlike
+// DeleteJobEntries it waits for the mutation to finish.
+func (c *ClickHouseClient) DeleteChunk(ctx context.Context, table, tenantID, jobID string, start, end int64) error {
+	if err := migratedTable(table); err != nil {
+		return err
+	}
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 2}))
+	if err := c.conn.Exec(ctx, `ALTER TABLE `+table+` DELETE WHERE `+chunkWhere, chunkArgs(tenantID, jobID, start, end)...); err != nil {
+		return fmt.Errorf("clickhouse: delete chunk: %w", err)
+	}
+	return nil
+}
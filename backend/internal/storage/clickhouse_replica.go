@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+type replicaReadKey struct{}
+
+// replicaRead returns a context whose queries may run on the read replica
+// of their cluster. The read-only analytics methods start with it; their
+// statements still run on the primary under WithPrimary.
+func replicaRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadKey{}, true)
+}
+
+// readsReplica reports whether the queries of ctx may run on a replica.
+func readsReplica(ctx context.Context) bool {
+	ok, _ := ctx.Value(replicaReadKey{}).(bool)
+	return ok && !UsesPrimary(ctx)
+}
+
+// replicaConn is a driver.Conn of a cluster with a read replica. The
+// queries of replicaRead contexts go to the replica while it is healthy,
+// and are retried on the primary when it fails; everything else runs on
+// the primary.
+type replicaConn struct {
+	driver.Conn // the primary
+
+	replica driver.Conn
+	health  *replicaHealth
+}
+
+// reader returns the replica when the queries of ctx may run on it.
+func (c *replicaConn) reader(ctx context.Context) (driver.Conn, bool) {
+	if readsReplica(ctx) && c.health.usable() {
+		return c.replica, true
+	}
+	return c.Conn, false
+}
+
+// fellBack records the outcome of a replica query and reports whether it
+// must be retried on the primary.
+func (c *replicaConn) fellBack(ctx context.Context, err error) bool {
+	if replicaFailed(ctx, err, isClickHouseQueryError) {
+		c.health.failure(err)
+		return true
+	}
+	if err == nil {
+		c.health.success()
+	}
+	return false
+}
+
+func (c *replicaConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	conn, onReplica := c.reader(ctx)
+	err := conn.Select(ctx, dest, query, args...)
+	if onReplica && c.fellBack(ctx, err) {
+		return c.Conn.Select(ctx, dest, query, args...)
+	}
+	return err
+}
+
+func (c *replicaConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	conn, onReplica := c.reader(ctx)
+	rows, err := conn.Query(ctx, query, args...)
+	if onReplica && c.fellBack(ctx, err) {
+		return c.Conn.Query(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (c *replicaConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	conn, onReplica := c.reader(ctx)
+	row := conn.QueryRow(ctx, query, args...)
+	if onReplica && c.fellBack(ctx, row.Err()) {
+		return c.Conn.QueryRow(ctx, query, args...)
+	}
+	return row
+}
+
+// Close closes the primary and the replica.
+func (c *replicaConn) Close() error {
+	return errors.Join(c.Conn.Close(), c.replica.Close())
+}
+
+// isClickHouseQueryError reports whether err is an exception raised by
+// the server for the query itself, or its lack of rows, which the primary
+// would return as well.
+func isClickHouseQueryError(err error) bool {
+	var ex *clickhouse.Exception
+	return errors.As(err, &ex) || errors.Is(err, sql.ErrNoRows)
+}
+
+// SetReadReplica connects to a read replica of the default cluster, which
+// the read-only analytics then query while it is healthy. It must be
+// called before the client is used.
+func (c *ClickHouseClient) SetReadReplica(ctx context.Context, dsn string, cfg ReplicaConfig) error {
+	opts, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("clickhouse: replica: parse dsn: %w", err)
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return fmt.Errorf("clickhouse: replica: open: %w", err)
+	}
+	if err := conn.Ping(ctx); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("clickhouse: replica: ping: %w", err)
+	}
+	c.setReadReplica(conn, newReplicaHealth(cfg, false))
+	return nil
+}
+
+func (c *ClickHouseClient) setReadReplica(replica driver.Conn, health *replicaHealth) {
+	c.router.mu.Lock()
+	defer c.router.mu.Unlock()
+	rc := &replicaConn{Conn: c.router.Conn, replica: replica, health: health}
+	c.router.Conn = rc
+	c.router.clusters[DefaultClickHouseCluster] = rc
+	c.replica = rc
+}
+
+// WatchReadReplica pings the read replica at the interval of its
+// configuration until ctx is done: a replica that stopped answering is no
+// longer read from, and one that answers again is read from at once.
+func (c *ClickHouseClient) WatchReadReplica(ctx context.Context) {
+	if c.replica == nil {
+		return
+	}
+	watchReplica(ctx, c.replica.health, func(ctx context.Context) (time.Duration, error) {
+		return 0, c.replica.replica.Ping(ctx)
+	})
+}
+
+// killOnReplica runs a KILL QUERY statement on the read replica, where the
+// statements of read-only methods may be running.
+func (c *ClickHouseClient) killOnReplica(ctx context.Context, query string, args ...any) error {
+	if c.replica == nil {
+		return nil
+	}
+	return c.replica.replica.Exec(ctx, query, args...)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// failingConn is a fakeConn whose queries fail with err when it is set.
+type failingConn struct {
+	*fakeConn
+	err error
+}
+
+func (c *failingConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	if c.err != nil {
+		c.queries++
+		return nil, c.err
+	}
+	return c.fakeConn.Query(ctx, query, args...)
+}
+
+// replicaClient is a client of a primary and its read replica.
+func replicaClient(t *testing.T) (*ClickHouseClient, *fakeConn, *failingConn) {
+	t.Helper()
+	primary := &fakeConn{rows: [][]any{{"API", uint64(3)}}}
+	replica := &failingConn{fakeConn: &fakeConn{rows: [][]any{{"API", uint64(3)}}}}
+	router := newClusterRouter(primary)
+	c := &ClickHouseClient{conn: taggedConn{router}, router: router}
+	health, _ := testReplicaHealth(false)
+	c.setReadReplica(replica, health)
+	return c, primary, replica
+}
+
+func TestClickHouseReplica_ReadsGoToReplica(t *testing.T) {
+	c, primary, replica := replicaClient(t)
+	ctx := context.Background()
+
+	counts, err := c.CountJobEntriesByType(ctx, testTenantID, testJobID)
+	require.NoError(t, err)
+	assert.Equal(t, map[domain.LogType]int64{domain.LogTypeAPI: 3}, counts)
+	assert.Equal(t, 1, replica.queries)
+	assert.Zero(t, primary.queries)
+
+	_, err = c.CountJobEntriesByType(WithPrimary(ctx), testTenantID, testJobID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, primary.queries, "WithPrimary pins reads to the primary")
+
+	require.NoError(t, c.DeleteChunk(ctx, "log_entries", testTenantID, testJobID, 0, 10))
+	assert.Len(t, primary.execs, 1, "writes go to the primary")
+	assert.Empty(t, replica.execs)
+}
+
+func TestClickHouseReplica_FallsBackToPrimary(t *testing.T) {
+	c, primary, replica := replicaClient(t)
+	ctx := context.Background()
+	replica.err = errors.New("read tcp: connection reset by peer")
+
+	for i := 0; i < 3; i++ {
+		counts, err := c.CountJobEntriesByType(ctx, testTenantID, testJobID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), counts[domain.LogTypeAPI])
+	}
+	assert.Equal(t, 2, replica.queries, "the breaker opens after two failures")
+	assert.Equal(t, 3, primary.queries)
+}
+
+func TestClickHouseReplica_QueryErrorsAreNotRetried(t *testing.T) {
+	c, primary, replica := replicaClient(t)
+	replica.err = &clickhouse.Exception{Code: 47, Message: "Missing columns"}
+
+	_, err := c.CountJobEntriesByType(context.Background(), testTenantID, testJobID)
+	require.Error(t, err)
+	assert.Zero(t, primary.queries)
+	assert.True(t, c.replica.health.usable())
+}
+
+func TestClickHouseReplica_KillQueryReachesReplica(t *testing.T) {
+	c, primary, replica := replicaClient(t)
+
+	require.NoError(t, c.KillQuery(context.Background(), "req-1"))
+	assert.Len(t, primary.execs, 1)
+	assert.Len(t, replica.execs, 1, "reads of the request may be running on the replica")
+}
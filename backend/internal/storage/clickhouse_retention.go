@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// RetentionPolicy is how long log entries of one retention class stay on the
+// hot volume and in ClickHouse at all.
+type RetentionPolicy struct {
+	HotDays       int `json:"hot_days"`       // Days before parts move to the cold volume
+	RetentionDays int `json:"retention_days"` // Days before rows are deleted
+}
+
+// RetentionPolicies maps each retention class to its policy. Trial data is
+// deleted before it would ever be moved, so it never reaches cold storage.
+var RetentionPolicies = map[domain.RetentionClass]RetentionPolicy{
+	domain.RetentionTrial:      {HotDays: 14, RetentionDays: 14},
+	domain.RetentionStandard:   {HotDays: 30, RetentionDays: 90},
+	domain.RetentionEnterprise: {HotDays: 90, RetentionDays: 365},
+}
+
+// RetentionClassForPlan returns the retention class of a tenant plan.
+// Unknown plans get the standard class.
+func RetentionClassForPlan(plan string) domain.RetentionClass {
+	switch strings.ToLower(strings.TrimSpace(plan)) {
+	case "trial":
+		return domain.RetentionTrial
+	case "enterprise":
+		return domain.RetentionEnterprise
+	default:
+		return domain.RetentionStandard
+	}
+}
+
+// RetentionClassForTenant returns the retention class of a tenant: that of
+// its plan, except for sandboxes, which keep their data for the trial
+// class whatever their plan.
+func RetentionClassForTenant(t *domain.Tenant) domain.RetentionClass {
+	if t.Sandbox {
+		return domain.RetentionTrial
+	}
+	return RetentionClassForPlan(t.Plan)
+}
+
+// TieringConfig names the ClickHouse storage policy and the volume that old
+// log_entries parts move to. An empty StoragePolicy disables tiering; rows
+// are then only deleted.
+type TieringConfig struct {
+	StoragePolicy string
+	ColdVolume    string
+}
+
+// RetentionTTL builds the log_entries TTL expression for RetentionPolicies.
+// Each class's interval is selected per row from retention_class, falling
+// back to the standard policy for unknown values.
+func RetentionTTL(tiering TieringConfig) string {
+	classes := make([]string, 0, len(RetentionPolicies))
+	for c := range RetentionPolicies {
+		if c != domain.RetentionStandard {
+			classes = append(classes, string(c))
+		}
+	}
+	sort.Strings(classes)
+
+	days := func(pick func(RetentionPolicy) int) string {
+		var b strings.Builder
+		b.WriteString("multiIf(")
+		for _, c := range classes {
+			fmt.Fprintf(&b, "retention_class = '%s', %d, ", c, pick(RetentionPolicies[domain.RetentionClass(c)]))
+		}
+		fmt.Fprintf(&b, "%d)", pick(RetentionPolicies[domain.RetentionStandard]))
+		return b.String()
+	}
+
+	deleteClause := fmt.Sprintf("toDateTime(timestamp) + toIntervalDay(%s) DELETE",
+		days(func(p RetentionPolicy) int { return p.RetentionDays }))
+	if tiering.StoragePolicy == "" || tiering.ColdVolume == "" {
+		return deleteClause
+	}
+	return fmt.Sprintf("toDateTime(timestamp) + toIntervalDay(%s) TO VOLUME '%s', %s",
+		days(func(p RetentionPolicy) int { return p.HotDays }), tiering.ColdVolume, deleteClause)
+}
+
+// retentionComment tags log_entries with a hash of its TTL so that
+// EnsureRetentionTTL can tell whether the expression is current.
+func retentionComment(ttl string) string {
+	sum := sha256.Sum256([]byte(ttl))
+	return "retention:" + hex.EncodeToString(sum[:6])
+}
+
+// EnsureRetentionTTL applies the storage policy and TTL for RetentionPolicies
+// to log_entries unless they are already in place, and reports whether it
+// changed the table. Existing parts pick up the new TTL on their next merge
+// rather than through an immediate rewrite.
+func (c *ClickHouseClient) EnsureRetentionTTL(ctx context.Context, tiering TieringConfig) (bool, error) {
+	ttl := RetentionTTL(tiering)
+	comment := retentionComment(ttl)
+
+	var current, policy string
+	if err := c.conn.QueryRow(ctx, `
+		SELECT comment, storage_policy
+		FROM system.tables
+		WHERE database = currentDatabase() AND name = 'log_entries'
+	`).Scan(&current, &policy); err != nil {
+		return false, fmt.Errorf("clickhouse: read log_entries settings: %w", err)
+	}
+	if current == comment && (tiering.StoragePolicy == "" || policy == tiering.StoragePolicy) {
+		return false, nil
+	}
+
+	if tiering.StoragePolicy != "" && policy != tiering.StoragePolicy {
+		if err := c.conn.Exec(ctx, fmt.Sprintf(
+			"ALTER TABLE log_entries MODIFY SETTING storage_policy = '%s'", tiering.StoragePolicy,
+		)); err != nil {
+			return false, fmt.Errorf("clickhouse: set storage policy: %w", err)
+		}
+	}
+
+	alterCtx := clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"materialize_ttl_after_modify": 0,
+	}))
+	if err := c.conn.Exec(alterCtx, "ALTER TABLE log_entries MODIFY TTL "+ttl); err != nil {
+		return false, fmt.Errorf("clickhouse: modify ttl: %w", err)
+	}
+	if err := c.conn.Exec(ctx, fmt.Sprintf("ALTER TABLE log_entries MODIFY COMMENT '%s'", comment)); err != nil {
+		return false, fmt.Errorf("clickhouse: record ttl version: %w", err)
+	}
+	return true, nil
+}
+
+// UpdateTenantRetentionClass restamps a tenant's stored log entries with a
+// new retention class. The mutation runs asynchronously in ClickHouse.
+func (c *ClickHouseClient) UpdateTenantRetentionClass(ctx context.Context, tenantID string, class domain.RetentionClass) error {
+	if err := c.conn.Exec(ctx, `
+		ALTER TABLE log_entries
+		UPDATE retention_class = @class
+		WHERE tenant_id = @tenantID AND retention_class != @class
+	`,
+		clickhouse.Named("class", string(class)),
+		clickhouse.Named("tenantID", tenantID),
+	); err != nil {
+		return fmt.Errorf("clickhouse: update tenant retention class: %w", err)
+	}
+	return nil
+}
+
+// GetTenantStorage sums the active log_entries parts per tenant and disk.
+// log_entries is partitioned by (tenant_id, month), so every part belongs to
+// exactly one tenant.
+func (c *ClickHouseClient) GetTenantStorage(ctx context.Context) ([]domain.TenantStorage, error) {
+	rows, err := c.conn.Query(ctx, `
+		SELECT partition, disk_name, sum(bytes_on_disk), sum(rows)
+		FROM system.parts
+		WHERE database = currentDatabase() AND table = 'log_entries' AND active
+		GROUP BY partition, disk_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: query tenant storage: %w", err)
+	}
+	defer rows.Close()
+
+	byTenant := make(map[string]*domain.TenantStorage)
+	for rows.Next() {
+		var partition, disk string
+		var bytes, count uint64
+		if err := rows.Scan(&partition, &disk, &bytes, &count); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan tenant storage: %w", err)
+		}
+		tenantID, ok := partitionTenant(partition)
+		if !ok {
+			continue
+		}
+		ts, ok := byTenant[tenantID]
+		if !ok {
+			ts = &domain.TenantStorage{TenantID: tenantID, BytesByDisk: make(map[string]uint64)}
+			byTenant[tenantID] = ts
+		}
+		ts.Rows += count
+		ts.Bytes += bytes
+		ts.BytesByDisk[disk] += bytes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: tenant storage rows: %w", err)
+	}
+
+	out := make([]domain.TenantStorage, 0, len(byTenant))
+	for _, ts := range byTenant {
+		out = append(out, *ts)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TenantID < out[j].TenantID })
+	return out, nil
+}
+
+// partitionTenant extracts the tenant ID from a log_entries partition value
+// as shown in system.parts, e.g. "('6f1c…',202602)".
+func partitionTenant(partition string) (string, bool) {
+	rest, ok := strings.CutPrefix(partition, "('")
+	if !ok {
+		return "", false
+	}
+	end := strings.IndexByte(rest, '\'')
+	if end <= 0 {
+		return "", false
+	}
+	return rest[:end], true
+}
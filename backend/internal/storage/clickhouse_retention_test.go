@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// fakeConn is a driver.Conn that serves canned rows and records statements.
+// Queries are answered from results in order while any remain, then from
+// rows; the last query and its arguments are kept. Methods it does not
+// override panic through the nil embedded interface.
+type fakeConn struct {
+	driver.Conn
+	rows      [][]any
+	results   [][][]any
+	row       []any
+	queries   int
+	lastQuery string
+	lastArgs  []any
+	execs     []string
+	execArgs  [][]any
+}
+
+func (c *fakeConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	c.queries++
+	c.lastQuery, c.lastArgs = query, args
+	if len(c.results) > 0 {
+		rows := c.results[0]
+		c.results = c.results[1:]
+		return &fakeRows{rows: rows, pos: -1}, nil
+	}
+	return &fakeRows{rows: c.rows, pos: -1}, nil
+}
+
+func (c *fakeConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return &fakeRow{values: c.row}
+}
+
+func (c *fakeConn) Exec(ctx context.Context, query string, args ...any) error {
+	c.execs = append(c.execs, strings.TrimSpace(query))
+	c.execArgs = append(c.execArgs, args)
+	return nil
+}
+
+type fakeRows struct {
+	driver.Rows
+	rows [][]any
+	pos  int
+}
+
+func (r *fakeRows) Next() bool             { r.pos++; return r.pos < len(r.rows) }
+func (r *fakeRows) Scan(dest ...any) error { scanValues(r.rows[r.pos], dest); return nil }
+func (r *fakeRows) Err() error             { return nil }
+func (r *fakeRows) Close() error           { return nil }
+
+type fakeRow struct {
+	driver.Row
+	values []any
+}
+
+func (r *fakeRow) Scan(dest ...any) error { scanValues(r.values, dest); return nil }
+func (r *fakeRow) Err() error             { return nil }
+
+func scanValues(values, dest []any) {
+	for i, v := range values {
+		if s, ok := dest[i].(sql.Scanner); ok {
+			_ = s.Scan(v)
+			continue
+		}
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Retention policies
+// ---------------------------------------------------------------------------
+
+func TestRetentionClassForPlan(t *testing.T) {
+	assert.Equal(t, domain.RetentionTrial, RetentionClassForPlan("trial"))
+	assert.Equal(t, domain.RetentionEnterprise, RetentionClassForPlan(" Enterprise "))
+	assert.Equal(t, domain.RetentionStandard, RetentionClassForPlan("free"))
+	assert.Equal(t, domain.RetentionStandard, RetentionClassForPlan(""))
+}
+
+func TestRetentionClassForTenant(t *testing.T) {
+	assert.Equal(t, domain.RetentionEnterprise, RetentionClassForTenant(&domain.Tenant{Plan: "enterprise"}))
+	assert.Equal(t, domain.RetentionTrial, RetentionClassForTenant(&domain.Tenant{Plan: "enterprise", Sandbox: true}),
+		"a sandbox's short retention overrides its plan")
+}
+
+func TestRetentionTTL(t *testing.T) {
+	deleteOnly := RetentionTTL(TieringConfig{ColdVolume: "cold"})
+	assert.Equal(t,
+		"toDateTime(timestamp) + toIntervalDay(multiIf(retention_class = 'enterprise', 365, retention_class = 'trial', 14, 90)) DELETE",
+		deleteOnly)
+
+	tiered := RetentionTTL(TieringConfig{StoragePolicy: "hot_cold", ColdVolume: "cold"})
+	assert.True(t, strings.HasPrefix(tiered,
+		"toDateTime(timestamp) + toIntervalDay(multiIf(retention_class = 'enterprise', 90, retention_class = 'trial', 14, 30)) TO VOLUME 'cold', "))
+	assert.True(t, strings.HasSuffix(tiered, deleteOnly))
+
+	assert.Equal(t, retentionComment(tiered), retentionComment(RetentionTTL(TieringConfig{StoragePolicy: "hot_cold", ColdVolume: "cold"})))
+	assert.NotEqual(t, retentionComment(tiered), retentionComment(deleteOnly))
+}
+
+func TestEnsureRetentionTTL(t *testing.T) {
+	tiering := TieringConfig{StoragePolicy: "hot_cold", ColdVolume: "cold"}
+	comment := retentionComment(RetentionTTL(tiering))
+
+	t.Run("up to date", func(t *testing.T) {
+		conn := &fakeConn{row: []any{comment, "hot_cold"}}
+		changed, err := (&ClickHouseClient{conn: conn}).EnsureRetentionTTL(context.Background(), tiering)
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Empty(t, conn.execs)
+	})
+
+	t.Run("stale ttl and policy", func(t *testing.T) {
+		conn := &fakeConn{row: []any{"", "default"}}
+		changed, err := (&ClickHouseClient{conn: conn}).EnsureRetentionTTL(context.Background(), tiering)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		require.Len(t, conn.execs, 3)
+		assert.Contains(t, conn.execs[0], "storage_policy = 'hot_cold'")
+		assert.Contains(t, conn.execs[1], "MODIFY TTL")
+		assert.Contains(t, conn.execs[2], comment)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// GetTenantStorage
+// ---------------------------------------------------------------------------
+
+func TestPartitionTenant(t *testing.T) {
+	id, ok := partitionTenant("('6f1c2d3e-0000-4000-8000-000000000001',202602)")
+	assert.True(t, ok)
+	assert.Equal(t, "6f1c2d3e-0000-4000-8000-000000000001", id)
+
+	for _, p := range []string{"202602", "('',202602)", ""} {
+		_, ok := partitionTenant(p)
+		assert.False(t, ok, p)
+	}
+}
+
+func TestGetTenantStorage(t *testing.T) {
+	conn := &fakeConn{rows: [][]any{
+		{"('b',202601)", "default", uint64(100), uint64(10)},
+		{"('a',202601)", "default", uint64(200), uint64(20)},
+		{"('a',202512)", "cold", uint64(50), uint64(5)},
+		{"tuple()", "default", uint64(999), uint64(99)},
+	}}
+
+	got, err := (&ClickHouseClient{conn: conn}).GetTenantStorage(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []domain.TenantStorage{
+		{TenantID: "a", Rows: 25, Bytes: 250, BytesByDisk: map[string]uint64{"default": 200, "cold": 50}},
+		{TenantID: "b", Rows: 10, Bytes: 100, BytesByDisk: map[string]uint64{"default": 100}},
+	}, got)
+}
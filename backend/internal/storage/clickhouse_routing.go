@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// DefaultClickHouseCluster names the cluster of CLICKHOUSE_URL, which
+// tenants without a route use.
+const DefaultClickHouseCluster = "default"
+
+type routeTenantKey struct{}
+
+// WithTenant returns a context whose ClickHouse statements run on the
+// cluster the tenant is routed to. Statements without a tenant run on the
+// default cluster.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, routeTenantKey{}, tenantID)
+}
+
+// clusterRouter is a driver.Conn that sends every statement to the cluster
+// of the tenant of its context. The routes are swapped whole by SetRoutes,
+// so a tenant moves to its new cluster between two statements.
+type clusterRouter struct {
+	driver.Conn // the default cluster
+
+	mu       sync.RWMutex
+	clusters map[string]driver.Conn
+	routes   map[string]string // tenant ID -> cluster
+
+	// region returns the data residency region of a tenant, whose cluster
+	// takes precedence over the routes. Nil without regions.
+	region func(tenantID string) (string, bool)
+}
+
+func newClusterRouter(def driver.Conn) *clusterRouter {
+	return &clusterRouter{
+		Conn:     def,
+		clusters: map[string]driver.Conn{DefaultClickHouseCluster: def},
+	}
+}
+
+// pick returns the connection of the cluster of the tenant of ctx.
+func (r *clusterRouter) pick(ctx context.Context) driver.Conn {
+	tenantID, _ := ctx.Value(routeTenantKey{}).(string)
+	if tenantID == "" {
+		return r.Conn
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.region != nil {
+		if region, ok := r.region(tenantID); ok {
+			if conn, ok := r.clusters[region]; ok {
+				return conn
+			}
+		}
+	}
+	if conn, ok := r.clusters[r.routes[tenantID]]; ok {
+		return conn
+	}
+	return r.Conn
+}
+
+// cluster returns the connection of a named cluster.
+func (r *clusterRouter) cluster(name string) (driver.Conn, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	conn, ok := r.clusters[name]
+	return conn, ok
+}
+
+// route returns the cluster a tenant is routed to.
+func (r *clusterRouter) route(tenantID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if cluster, ok := r.routes[tenantID]; ok {
+		return cluster
+	}
+	return DefaultClickHouseCluster
+}
+
+func (r *clusterRouter) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return r.pick(ctx).Select(ctx, dest, query, args...)
+}
+
+func (r *clusterRouter) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	return r.pick(ctx).Query(ctx, query, args...)
+}
+
+func (r *clusterRouter) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return r.pick(ctx).QueryRow(ctx, query, args...)
+}
+
+func (r *clusterRouter) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	return r.pick(ctx).PrepareBatch(ctx, query, opts...)
+}
+
+func (r *clusterRouter) Exec(ctx context.Context, query string, args ...any) error {
+	return r.pick(ctx).Exec(ctx, query, args...)
+}
+
+func (r *clusterRouter) AsyncInsert(ctx context.Context, query string, wait bool, args ...any) error {
+	return r.pick(ctx).AsyncInsert(ctx, query, wait, args...)
+}
+
+// Close closes the connections of every cluster.
+func (r *clusterRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, conn := range r.clusters {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AddCluster connects to a ClickHouse cluster that tenants can be routed
+// to by name.
+func (c *ClickHouseClient) AddCluster(ctx context.Context, name, dsn string) error {
+	if name == "" || name == DefaultClickHouseCluster {
+		return fmt.Errorf("clickhouse: cluster name %q is reserved", name)
+	}
+	opts, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("clickhouse: cluster %s: parse dsn: %w", name, err)
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return fmt.Errorf("clickhouse: cluster %s: open: %w", name, err)
+	}
+	if err := conn.Ping(ctx); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("clickhouse: cluster %s: ping: %w", name, err)
+	}
+
+	c.setCluster(name, conn)
+	return nil
+}
+
+// AddLazyCluster adds a cluster like AddCluster, but only checks its DSN:
+// connections are opened by the first statement routed to it.
+func (c *ClickHouseClient) AddLazyCluster(name, dsn string) error {
+	if name == "" || name == DefaultClickHouseCluster {
+		return fmt.Errorf("clickhouse: cluster name %q is reserved", name)
+	}
+	opts, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("clickhouse: cluster %s: parse dsn: %w", name, err)
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return fmt.Errorf("clickhouse: cluster %s: open: %w", name, err)
+	}
+	c.setCluster(name, conn)
+	return nil
+}
+
+func (c *ClickHouseClient) setCluster(name string, conn driver.Conn) {
+	c.router.mu.Lock()
+	defer c.router.mu.Unlock()
+	if old, ok := c.router.clusters[name]; ok {
+		_ = old.Close()
+	}
+	c.router.clusters[name] = conn
+}
+
+// SetRoutes replaces the clusters tenants are routed to. Routes to clusters
+// that were not added are refused, and the previous routes kept, so that a
+// tenant never reads from a cluster that does not hold its data.
+func (c *ClickHouseClient) SetRoutes(routes map[string]string) error {
+	c.router.mu.Lock()
+	defer c.router.mu.Unlock()
+	var unknown []string
+	for tenantID, cluster := range routes {
+		if _, ok := c.router.clusters[cluster]; !ok {
+			unknown = append(unknown, tenantID+"="+cluster)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("clickhouse: routes to unknown clusters: %s", strings.Join(unknown, ", "))
+	}
+	c.router.routes = routes
+	return nil
+}
+
+// Route returns the cluster a tenant is routed to.
+func (c *ClickHouseClient) Route(tenantID string) string {
+	return c.router.route(tenantID)
+}
+
+// Cluster returns a client of one cluster, whatever the routes. It shares
+// the connection of the client and must not be closed.
+func (c *ClickHouseClient) Cluster(name string) (*ClickHouseClient, error) {
+	conn, ok := c.router.cluster(name)
+	if !ok {
+		return nil, fmt.Errorf("clickhouse: unknown cluster %q", name)
+	}
+	router := newClusterRouter(conn)
+	return &ClickHouseClient{conn: taggedConn{router}, router: router, rollups: newRollupIndex()}, nil
+}
+
+// RefreshRoutes loads the tenant routes from Postgres.
+func (c *ClickHouseClient) RefreshRoutes(ctx context.Context, pg PostgresStore) error {
+	stored, err := pg.ListClickHouseRoutes(ctx)
+	if err != nil {
+		return err
+	}
+	routes := make(map[string]string, len(stored))
+	for tenantID, cluster := range stored {
+		routes[tenantID.String()] = cluster
+	}
+	return c.SetRoutes(routes)
+}
+
+// WatchRoutes reloads the tenant routes every interval until ctx is done,
+// so that a tenant migration's cutover reaches every service.
+func (c *ClickHouseClient) WatchRoutes(ctx context.Context, pg PostgresStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.RefreshRoutes(ctx, pg); err != nil {
+				slog.Error("refresh clickhouse routes failed", "error", err)
+			}
+		}
+	}
+}
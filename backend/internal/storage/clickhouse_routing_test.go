@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func routedClient(def, eu *fakeConn) *ClickHouseClient {
+	router := newClusterRouter(def)
+	router.clusters["eu"] = eu
+	return &ClickHouseClient{conn: taggedConn{router}, router: router}
+}
+
+func TestClusterRouter_RoutesByTenantOfContext(t *testing.T) {
+	def, eu := &fakeConn{}, &fakeConn{}
+	c := routedClient(def, eu)
+	require.NoError(t, c.SetRoutes(map[string]string{"t-eu": "eu"}))
+
+	ctx := context.Background()
+	require.NoError(t, c.DeleteChunk(WithTenant(ctx, "t-eu"), "log_entries", "t-eu", "j", 0, 10))
+	require.NoError(t, c.DeleteChunk(WithTenant(ctx, "t-other"), "log_entries", "t-other", "j", 0, 10))
+	require.NoError(t, c.DeleteChunk(ctx, "log_entries", "t-eu", "j", 0, 10))
+
+	assert.Len(t, eu.execs, 1)
+	assert.Len(t, def.execs, 2)
+	assert.Equal(t, "eu", c.Route("t-eu"))
+	assert.Equal(t, DefaultClickHouseCluster, c.Route("t-other"))
+}
+
+func TestClickHouseClient_SetRoutesRefusesUnknownCluster(t *testing.T) {
+	c := routedClient(&fakeConn{}, &fakeConn{})
+	require.NoError(t, c.SetRoutes(map[string]string{"t1": "eu"}))
+
+	err := c.SetRoutes(map[string]string{"t1": "eu", "t2": "apac"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "t2=apac")
+	assert.Equal(t, "eu", c.Route("t1"), "previous routes are kept")
+}
+
+func TestClickHouseClient_ClusterIgnoresRoutes(t *testing.T) {
+	def, eu := &fakeConn{}, &fakeConn{}
+	c := routedClient(def, eu)
+	require.NoError(t, c.SetRoutes(map[string]string{"t1": "eu"}))
+
+	d, err := c.Cluster(DefaultClickHouseCluster)
+	require.NoError(t, err)
+	require.NoError(t, d.DeleteChunk(WithTenant(context.Background(), "t1"), "log_entries", "t1", "j", 0, 10))
+	assert.Len(t, def.execs, 1)
+	assert.Empty(t, eu.execs)
+
+	_, err = c.Cluster("apac")
+	assert.Error(t, err)
+}
+
+func TestAddCluster_ReservedName(t *testing.T) {
+	c := routedClient(&fakeConn{}, &fakeConn{})
+	assert.Error(t, c.AddCluster(context.Background(), DefaultClickHouseCluster, "clickhouse://localhost:9000"))
+}
+
+func TestMigratedTable(t *testing.T) {
+	assert.NoError(t, migratedTable("log_entries"))
+	c := &ClickHouseClient{conn: &fakeConn{}}
+	_, err := c.ReadChunk(context.Background(), "log_entries; DROP TABLE x", "t", "j", 0, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// ErrSearchTooBroad is returned by searches that ClickHouse stopped at the
+// search limits: they read too many rows or used too much memory, and
+// need narrower filters.
+var ErrSearchTooBroad = errors.New("clickhouse: search too broad")
+
+// searchLimitCodes are the codes of the exceptions raised for a statement
+// over its max_rows_to_read or max_memory_usage.
+var searchLimitCodes = map[int32]bool{
+	158: true, // TOO_MANY_ROWS
+	241: true, // MEMORY_LIMIT_EXCEEDED
+	307: true, // TOO_MANY_BYTES
+}
+
+// SearchLimits bound each statement of an interactive search: the rows it
+// may read and the memory it may use. Zero leaves a limit to the server.
+type SearchLimits struct {
+	MaxRowsToRead  uint64
+	MaxMemoryBytes uint64
+}
+
+// SetSearchLimits applies limits to the statements of SearchEntries and
+// GetFacets. Exports are not limited. It must be called before the client
+// is used.
+func (c *ClickHouseClient) SetSearchLimits(limits SearchLimits) {
+	c.searchLimits = limits
+}
+
+// limitSearch returns ctx with the search limits as the settings of its
+// statements.
+func (c *ClickHouseClient) limitSearch(ctx context.Context) context.Context {
+	settings := clickhouse.Settings{}
+	if c.searchLimits.MaxRowsToRead > 0 {
+		settings["max_rows_to_read"] = c.searchLimits.MaxRowsToRead
+	}
+	if c.searchLimits.MaxMemoryBytes > 0 {
+		settings["max_memory_usage"] = c.searchLimits.MaxMemoryBytes
+	}
+	if len(settings) == 0 {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+}
+
+// searchError wraps the error of a search statement, as ErrSearchTooBroad
+// when the statement was stopped at the search limits.
+func searchError(op string, err error) error {
+	var ex *clickhouse.Exception
+	if errors.As(err, &ex) && searchLimitCodes[ex.Code] {
+		return fmt.Errorf("clickhouse: %s: %w: %s", op, ErrSearchTooBroad, ex.Message)
+	}
+	return fmt.Errorf("clickhouse: %s: %w", op, err)
+}
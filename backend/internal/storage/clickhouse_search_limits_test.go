@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchError(t *testing.T) {
+	for _, code := range []int32{158, 241, 307} {
+		ex := &clickhouse.Exception{Code: code, Message: "limit exceeded"}
+		err := searchError("search count", fmt.Errorf("read: %w", ex))
+		assert.ErrorIs(t, err, ErrSearchTooBroad, "code %d", code)
+		assert.ErrorContains(t, err, "clickhouse: search count")
+	}
+
+	ex := &clickhouse.Exception{Code: 60, Message: "unknown table"}
+	err := searchError("search query", ex)
+	assert.NotErrorIs(t, err, ErrSearchTooBroad)
+	assert.ErrorIs(t, err, ex)
+
+	err = searchError("rows", errors.New("connection reset"))
+	assert.NotErrorIs(t, err, ErrSearchTooBroad)
+	assert.EqualError(t, err, "clickhouse: rows: connection reset")
+}
+
+func TestLimitSearch(t *testing.T) {
+	ctx := context.Background()
+	c := &ClickHouseClient{}
+	assert.Equal(t, ctx, c.limitSearch(ctx))
+
+	c.SetSearchLimits(SearchLimits{MaxRowsToRead: 1000, MaxMemoryBytes: 1 << 20})
+	assert.NotEqual(t, ctx, c.limitSearch(ctx))
+}
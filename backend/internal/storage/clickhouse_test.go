@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
 )
 
 func clickhouseDSN() string {
@@ -52,8 +54,8 @@ func TestClickHouse_BatchInsertAndGetEntry(t *testing.T) {
 			EntryID:    "entry-001",
 			LineNumber: 1,
 			FileNumber: 1,
-			Timestamp:  time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
-			IngestedAt: time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType:    domain.LogTypeAPI,
 			TraceID:    "trace-001",
 			RPCID:      "rpc-001",
@@ -72,8 +74,8 @@ func TestClickHouse_BatchInsertAndGetEntry(t *testing.T) {
 			EntryID:    "entry-002",
 			LineNumber: 2,
 			FileNumber: 1,
-			Timestamp:  time.Date(2025, 1, 15, 10, 0, 1, 0, time.UTC),
-			IngestedAt: time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Date(2025, 1, 15, 10, 0, 1, 0, time.UTC)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType:    domain.LogTypeSQL,
 			TraceID:    "trace-001",
 			RPCID:      "rpc-002",
@@ -84,6 +86,8 @@ func TestClickHouse_BatchInsertAndGetEntry(t *testing.T) {
 			Success:    true,
 			SQLTable:   "T001",
 			RawText:    "SELECT * FROM T001",
+
+			RawTextTruncated: true,
 		},
 		{
 			TenantID:   tenantID,
@@ -91,8 +95,8 @@ func TestClickHouse_BatchInsertAndGetEntry(t *testing.T) {
 			EntryID:    "entry-003",
 			LineNumber: 3,
 			FileNumber: 1,
-			Timestamp:  time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC),
-			IngestedAt: time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType:    domain.LogTypeFilter,
 			TraceID:    "trace-002",
 			RPCID:      "rpc-003",
@@ -122,6 +126,11 @@ func TestClickHouse_BatchInsertAndGetEntry(t *testing.T) {
 	assert.Equal(t, uint32(150), entry.DurationMS)
 	assert.Equal(t, "GetEntry", entry.APICode)
 	assert.Equal(t, "HPD:Help Desk", entry.Form)
+	assert.False(t, entry.RawTextTruncated)
+
+	entry, err = client.GetLogEntry(ctx, tenantID, jobID, "entry-002")
+	require.NoError(t, err)
+	assert.True(t, entry.RawTextTruncated)
 
 	// Retrieve non-existent entry.
 	_, err = client.GetLogEntry(ctx, tenantID, jobID, "nonexistent")
@@ -159,8 +168,8 @@ func TestClickHouse_SearchEntries(t *testing.T) {
 			EntryID:    fmt.Sprintf("search-entry-%03d", i),
 			LineNumber: uint32(i + 1),
 			FileNumber: 1,
-			Timestamp:  base.Add(time.Duration(i) * time.Second),
-			IngestedAt: time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Second)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType:    lt,
 			User:       "SearchUser",
 			Queue:      "TestQueue",
@@ -213,6 +222,27 @@ func TestClickHouse_SearchEntries(t *testing.T) {
 	assert.Equal(t, int64(30), result.TotalCount)
 	assert.Len(t, result.Entries, 5)
 
+	// Sort by a computed column: net_ms is 100+10i - 20(i+1) = 80-10i, so
+	// the last entry seeded sorts first.
+	computed, err := search.CompileComputed([]search.ComputedColumn{
+		{Name: "net_ms", Expr: "duration_ms - line_number * 20"},
+		{Name: "shout", Expr: "concat(upper(user), '!')"},
+	})
+	require.NoError(t, err)
+	result, err = client.SearchEntries(ctx, tenantID, jobID, SearchQuery{
+		Page:      1,
+		PageSize:  3,
+		SortBy:    "net_ms",
+		SortOrder: "asc",
+		Computed:  computed,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 3)
+	require.Len(t, result.Computed, 3)
+	assert.Equal(t, "search-entry-029", result.Entries[0].EntryID)
+	assert.Equal(t, float64(-210), result.Computed[0]["net_ms"])
+	assert.Equal(t, "SEARCHUSER!", result.Computed[0]["shout"])
+
 	// Tenant isolation.
 	result, err = client.SearchEntries(ctx, "other-tenant-search", jobID, SearchQuery{
 		Page:     1,
@@ -222,6 +252,78 @@ func TestClickHouse_SearchEntries(t *testing.T) {
 	assert.Equal(t, int64(0), result.TotalCount)
 }
 
+func TestClickHouse_SearchEntriesSortStability(t *testing.T) {
+	client := setupClickHouse(t)
+	ctx := context.Background()
+
+	tenantID := "test-tenant-ch-sort"
+	jobID := "test-job-ch-sort"
+
+	// Users repeat with differing case, and pairs of entries share a
+	// timestamp, so only the tiebreakers order them.
+	users := []string{"bob", "alice", "Bob", "Carol", "alice", "Alice"}
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var entries []domain.LogEntry
+	for i := 0; i < 24; i++ {
+		entries = append(entries, domain.LogEntry{
+			TenantID:   tenantID,
+			JobID:      jobID,
+			EntryID:    fmt.Sprintf("sort-entry-%03d", i),
+			LineNumber: uint32(24 - i),
+			FileNumber: 1,
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i/2) * time.Second)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
+			LogType:    domain.LogTypeAPI,
+			User:       users[i%len(users)],
+			DurationMS: 100,
+		})
+	}
+	require.NoError(t, client.BatchInsertEntries(ctx, entries))
+	time.Sleep(2 * time.Second)
+
+	// read pages through the whole job sorted by user.
+	read := func() []domain.LogEntry {
+		var out []domain.LogEntry
+		for page := 1; page <= 4; page++ {
+			result, err := client.SearchEntries(ctx, tenantID, jobID, SearchQuery{
+				Page:      page,
+				PageSize:  6,
+				SortBy:    "user",
+				SortOrder: "asc",
+			})
+			require.NoError(t, err)
+			out = append(out, result.Entries...)
+		}
+		return out
+	}
+
+	first := read()
+	require.Len(t, first, 24)
+	for i := 1; i < len(first); i++ {
+		prev, cur := first[i-1], first[i]
+		prevUser, curUser := strings.ToLower(prev.User), strings.ToLower(cur.User)
+		require.LessOrEqual(t, prevUser, curUser, "users sort case-insensitively")
+		if prevUser != curUser {
+			continue
+		}
+		if !prev.Timestamp.Equal(cur.Timestamp.Time) {
+			assert.True(t, prev.Timestamp.Before(cur.Timestamp.Time), "ties on user sort by timestamp")
+			continue
+		}
+		assert.Less(t, prev.LineNumber, cur.LineNumber, "ties on timestamp sort by line number")
+	}
+	assert.Equal(t, "alice", strings.ToLower(first[0].User))
+	assert.Equal(t, "carol", strings.ToLower(first[23].User))
+
+	for n := 0; n < 3; n++ {
+		again := read()
+		require.Len(t, again, len(first))
+		for i := range first {
+			assert.Equal(t, first[i].EntryID, again[i].EntryID, "position %d", i)
+		}
+	}
+}
+
 func TestClickHouse_GetTraceEntries(t *testing.T) {
 	client := setupClickHouse(t)
 	ctx := context.Background()
@@ -234,19 +336,19 @@ func TestClickHouse_GetTraceEntries(t *testing.T) {
 		{
 			TenantID: tenantID, JobID: jobID, EntryID: "trace-e1",
 			LineNumber: 1, FileNumber: 1,
-			Timestamp: time.Date(2025, 2, 1, 8, 0, 0, 0, time.UTC), IngestedAt: time.Now().UTC(),
+			Timestamp: domain.NewTimestamp(time.Date(2025, 2, 1, 8, 0, 0, 0, time.UTC)), IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType: domain.LogTypeAPI, TraceID: traceID, DurationMS: 100,
 		},
 		{
 			TenantID: tenantID, JobID: jobID, EntryID: "trace-e2",
 			LineNumber: 2, FileNumber: 1,
-			Timestamp: time.Date(2025, 2, 1, 8, 0, 1, 0, time.UTC), IngestedAt: time.Now().UTC(),
+			Timestamp: domain.NewTimestamp(time.Date(2025, 2, 1, 8, 0, 1, 0, time.UTC)), IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType: domain.LogTypeSQL, TraceID: traceID, DurationMS: 50,
 		},
 		{
 			TenantID: tenantID, JobID: jobID, EntryID: "trace-e3",
 			LineNumber: 3, FileNumber: 1,
-			Timestamp: time.Date(2025, 2, 1, 8, 0, 2, 0, time.UTC), IngestedAt: time.Now().UTC(),
+			Timestamp: domain.NewTimestamp(time.Date(2025, 2, 1, 8, 0, 2, 0, time.UTC)), IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType: domain.LogTypeAPI, TraceID: "other-trace", DurationMS: 75,
 		},
 	}
@@ -294,8 +396,8 @@ func TestClickHouse_GetDashboardData(t *testing.T) {
 			EntryID:    fmt.Sprintf("dash-entry-%03d", i),
 			LineNumber: uint32(i + 1),
 			FileNumber: 1,
-			Timestamp:  base.Add(time.Duration(i) * time.Minute),
-			IngestedAt: time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Minute)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
 			LogType:    lt,
 			Queue:      "DashQueue",
 			User:       fmt.Sprintf("user%d", i%3),
@@ -351,3 +453,211 @@ func TestClickHouse_GetDashboardData(t *testing.T) {
 	assert.True(t, ok, "distribution should contain by_queue")
 	assert.Greater(t, byQueue["DashQueue"], 0)
 }
+
+func TestClickHouse_GetDashboardData_SkipsAbsentLogTypes(t *testing.T) {
+	client := setupClickHouse(t)
+	ctx := context.Background()
+
+	tenantID := "test-tenant-ch-dash-absent"
+	jobID := "test-job-ch-dash-absent"
+
+	base := time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC)
+	var entries []domain.LogEntry
+	for i := 0; i < 4; i++ {
+		entries = append(entries, domain.LogEntry{
+			TenantID:   tenantID,
+			JobID:      jobID,
+			EntryID:    fmt.Sprintf("absent-entry-%03d", i),
+			LineNumber: uint32(i + 1),
+			FileNumber: 1,
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Minute)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
+			LogType:    domain.LogTypeAPI,
+			User:       "Demo",
+			DurationMS: uint32(100 + i),
+			Success:    true,
+			APICode:    "GE",
+		})
+	}
+	require.NoError(t, client.BatchInsertEntries(ctx, entries))
+	time.Sleep(2 * time.Second)
+
+	dash, err := client.GetDashboardData(ctx, tenantID, jobID, 5)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"sql", "filters", "escalations"}, dash.NoData)
+	assert.NotEmpty(t, dash.TopAPICalls)
+	assert.NotNil(t, dash.TopSQL)
+	assert.Empty(t, dash.TopSQL)
+	assert.Empty(t, dash.TopEscalations)
+}
+
+func TestClickHouse_GetQueueLoad(t *testing.T) {
+	client := setupClickHouse(t)
+	ctx := context.Background()
+
+	tenantID := "test-tenant-ch-queue-load"
+	jobID := "test-job-ch-queue-load"
+
+	// Two Fast threads over two minutes; the second minute is the busier.
+	base := time.Date(2025, 4, 3, 10, 0, 0, 0, time.UTC)
+	calls := []struct {
+		offset     time.Duration
+		thread     string
+		logType    domain.LogType
+		durationMS uint32
+	}{
+		{0, "T1", domain.LogTypeAPI, 10_000},
+		{70 * time.Second, "T1", domain.LogTypeAPI, 30_000},
+		{80 * time.Second, "T2", domain.LogTypeAPI, 20_000},
+		{90 * time.Second, "T2", domain.LogTypeSQL, 15_000}, // within the API call
+		{120 * time.Second, "T3", domain.LogTypeAPI, 0},
+	}
+	var entries []domain.LogEntry
+	for i, c := range calls {
+		entries = append(entries, domain.LogEntry{
+			TenantID:   tenantID,
+			JobID:      jobID,
+			EntryID:    fmt.Sprintf("queue-load-entry-%03d", i),
+			LineNumber: uint32(i + 1),
+			FileNumber: 1,
+			Timestamp:  domain.NewTimestamp(base.Add(c.offset)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
+			LogType:    c.logType,
+			ThreadID:   c.thread,
+			Queue:      "Fast",
+			DurationMS: c.durationMS,
+			Success:    true,
+		})
+	}
+	require.NoError(t, client.BatchInsertEntries(ctx, entries))
+	time.Sleep(2 * time.Second)
+
+	loads, err := client.GetQueueLoad(ctx, tenantID, jobID)
+	require.NoError(t, err)
+	require.Len(t, loads, 1)
+
+	fast := loads[0]
+	assert.Equal(t, "Fast", fast.Queue)
+	assert.Equal(t, 3, fast.ObservedThreads)
+	assert.Equal(t, int64(60_000), fast.BusyMS, "SQL time is not counted twice")
+	assert.Equal(t, int64(120_000), fast.SpanMS)
+	assert.True(t, fast.PeakMinute.Equal(base.Add(time.Minute)))
+	assert.Equal(t, int64(50_000), fast.PeakMinuteBusyMS)
+
+	report := ThreadCapacityReport(loads, map[string]int{"Fast": 4})
+	require.Len(t, report.Queues, 1)
+	assert.Equal(t, 12.5, report.Queues[0].BusyPct)
+	assert.Equal(t, 20.8, report.Queues[0].PeakMinuteBusyPct)
+}
+
+// TestClickHouse_ZeroDurationMetrics stores one thread's calls, 60% of
+// them timed at zero, and reads the raw and timed-only metrics of the
+// aggregates and the thread's busy percentages.
+func TestClickHouse_ZeroDurationMetrics(t *testing.T) {
+	client := setupClickHouse(t)
+	ctx := context.Background()
+
+	tenantID := "test-tenant-ch-zero-duration"
+	jobID := "test-job-ch-zero-duration"
+
+	base := time.Date(2025, 4, 3, 10, 0, 0, 0, time.UTC)
+	var entries []domain.LogEntry
+	for i := range 20 {
+		var durationMS uint32
+		if i >= 12 {
+			durationMS = uint32(100 * (i - 11)) // 100 to 800
+		}
+		entries = append(entries, domain.LogEntry{
+			TenantID:   tenantID,
+			JobID:      jobID,
+			EntryID:    fmt.Sprintf("zero-duration-entry-%03d", i),
+			LineNumber: uint32(i + 1),
+			FileNumber: 1,
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Second)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
+			LogType:    domain.LogTypeAPI,
+			ThreadID:   "T1",
+			Form:       "HPD:Help Desk",
+			DurationMS: durationMS,
+			Success:    true,
+		})
+	}
+	require.NoError(t, client.BatchInsertEntries(ctx, entries))
+	time.Sleep(2 * time.Second)
+
+	section, err := client.queryAggregateGroups(ctx, tenantID, jobID, "API", "form", nil)
+	require.NoError(t, err)
+	require.Len(t, section.Groups, 1)
+	g := section.Groups[0]
+	assert.Equal(t, int64(20), g.Count)
+	assert.Equal(t, 180.0, g.AvgMS)
+	assert.Equal(t, int64(8), g.TimedCount)
+	assert.Equal(t, 450.0, g.AvgTimedMS)
+	assert.Greater(t, g.P95TimedMS, g.AvgTimedMS)
+	require.NotNil(t, section.GrandTotal)
+	assert.Equal(t, int64(8), section.GrandTotal.TimedCount)
+	assert.Equal(t, 450.0, section.GrandTotal.AvgTimedMS)
+
+	// 3600ms busy, or 3612ms with the zero calls at the resolution, over
+	// the 19s the thread was active.
+	threads, err := client.GetThreadStats(ctx, tenantID, jobID)
+	require.NoError(t, err)
+	require.Len(t, threads.Threads, 1)
+	assert.InDelta(t, 18.95, threads.Threads[0].BusyPct, 0.01)
+	assert.InDelta(t, 19.01, threads.Threads[0].BusyPctResolved, 0.01)
+}
+
+func TestClickHouse_NoiseAndIngestionFilterMatches(t *testing.T) {
+	client := setupClickHouse(t)
+	ctx := context.Background()
+
+	tenantID := "test-tenant-ch-noise"
+	jobID := "test-job-ch-noise"
+
+	base := time.Date(2025, 4, 3, 10, 0, 0, 0, time.UTC)
+	users := []struct {
+		user  string
+		noise bool
+	}{
+		{"HealthCheckUser", true},
+		{"healthcheckuser", true},
+		{"Demo", false},
+	}
+	var entries []domain.LogEntry
+	for i, u := range users {
+		entries = append(entries, domain.LogEntry{
+			TenantID:   tenantID,
+			JobID:      jobID,
+			EntryID:    fmt.Sprintf("noise-entry-%03d", i),
+			LineNumber: uint32(i + 1),
+			FileNumber: 1,
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Second)),
+			IngestedAt: domain.NewTimestamp(time.Now().UTC()),
+			LogType:    domain.LogTypeAPI,
+			User:       u.user,
+			Success:    true,
+			IsNoise:    u.noise,
+		})
+	}
+	require.NoError(t, client.BatchInsertEntries(ctx, entries))
+	time.Sleep(2 * time.Second)
+
+	res, err := client.SearchEntries(ctx, tenantID, jobID, SearchQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.TotalCount, "noise is left out by default")
+
+	res, err = client.SearchEntries(ctx, tenantID, jobID, SearchQuery{IncludeNoise: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), res.TotalCount)
+
+	rule := domain.IngestionFilterRule{Field: "user", Operator: domain.IngestionFilterEquals, Value: "HealthCheckUser"}
+	n, err := client.CountIngestionFilterMatches(ctx, tenantID, jobID, rule)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	rule.CaseSensitive = true
+	n, err = client.CountIngestionFilterMatches(ctx, tenantID, jobID, rule)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -2,8 +2,12 @@ package storage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -577,3 +581,88 @@ func TestGetDashboardData_TopNDefault(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Error heatmap
+// ---------------------------------------------------------------------------
+
+func TestIntervalDuration(t *testing.T) {
+	assert.Equal(t, 5*time.Second, intervalDuration("5 SECOND"))
+	assert.Equal(t, time.Minute, intervalDuration("1 MINUTE"))
+	assert.Equal(t, 6*time.Hour, intervalDuration("6 HOUR"))
+	assert.Equal(t, time.Duration(0), intervalDuration("1 DAY"))
+	assert.Equal(t, time.Duration(0), intervalDuration("garbage"))
+}
+
+func TestResolveHeatmapBucketSize(t *testing.T) {
+	start := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "5 MINUTE", resolveHeatmapBucketSize("auto", start, start.Add(3*time.Hour)))
+	assert.Equal(t, "1 MINUTE", resolveHeatmapBucketSize("1m", start, start.Add(3*time.Hour)))
+	assert.Equal(t, "5 MINUTE", resolveHeatmapBucketSize("5m", start, start.Add(10*time.Minute)))
+	// 1m over a week would exceed maxHeatmapBuckets, so the auto size wins.
+	assert.Equal(t, "1 HOUR", resolveHeatmapBucketSize("1m", start, start.Add(7*24*time.Hour)))
+}
+
+func TestBuildErrorHeatmap_DenseAlignedBuckets(t *testing.T) {
+	rangeStart := time.Date(2026, 2, 3, 10, 2, 30, 0, time.UTC)
+	rangeEnd := time.Date(2026, 2, 3, 10, 21, 10, 0, time.UTC)
+
+	cells := []heatmapCell{
+		{ErrorCode: "ARERR 302", Bucket: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC), Count: 2},
+		{ErrorCode: "ARERR 302", Bucket: time.Date(2026, 2, 3, 10, 15, 0, 0, time.UTC), Count: 7},
+		{ErrorCode: "ARERR 93", Bucket: time.Date(2026, 2, 3, 10, 5, 0, 0, time.UTC), Count: 1},
+	}
+
+	resp := buildErrorHeatmap(cells, rangeStart, rangeEnd, "5 MINUTE", 10)
+
+	require.Len(t, resp.Buckets, 5)
+	for i, b := range resp.Buckets {
+		assert.Equal(t, time.Date(2026, 2, 3, 10, i*5, 0, 0, time.UTC), b.Time)
+	}
+
+	require.Len(t, resp.Rows, 2)
+	assert.Equal(t, "ARERR 302", resp.Rows[0].ErrorCode)
+	assert.Equal(t, []int64{2, 0, 0, 7, 0}, resp.Rows[0].Counts)
+	assert.Equal(t, int64(9), resp.Rows[0].Total)
+	assert.Equal(t, 3, resp.Rows[0].PeakBucket)
+
+	assert.Equal(t, "ARERR 93", resp.Rows[1].ErrorCode)
+	assert.Equal(t, []int64{0, 1, 0, 0, 0}, resp.Rows[1].Counts)
+	assert.Equal(t, 1, resp.Rows[1].PeakBucket)
+	assert.Equal(t, int64(10), resp.TotalCount)
+}
+
+func TestBuildErrorHeatmap_OtherRow(t *testing.T) {
+	rangeStart := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	rangeEnd := rangeStart.Add(2 * time.Minute)
+
+	cells := []heatmapCell{
+		{ErrorCode: "A", Bucket: rangeStart, Count: 10},
+		{ErrorCode: "B", Bucket: rangeStart.Add(time.Minute), Count: 8},
+		{ErrorCode: "C", Bucket: rangeStart, Count: 3},
+		{ErrorCode: "D", Bucket: rangeStart.Add(2 * time.Minute), Count: 2},
+		{ErrorCode: "D", Bucket: rangeStart.Add(time.Minute), Count: 2},
+	}
+
+	resp := buildErrorHeatmap(cells, rangeStart, rangeEnd, "1 MINUTE", 2)
+
+	require.Len(t, resp.Rows, 3)
+	assert.Equal(t, "A", resp.Rows[0].ErrorCode)
+	assert.Equal(t, "B", resp.Rows[1].ErrorCode)
+
+	other := resp.Rows[2]
+	assert.True(t, other.IsOther)
+	assert.Equal(t, domain.ErrorHeatmapOtherCode, other.ErrorCode)
+	assert.Equal(t, []int64{3, 2, 2}, other.Counts)
+	assert.Equal(t, int64(7), other.Total)
+	assert.Equal(t, 0, other.PeakBucket)
+	assert.Equal(t, int64(25), resp.TotalCount)
+}
+
+func TestBuildErrorHeatmap_NoEntries(t *testing.T) {
+	resp := buildErrorHeatmap(nil, time.Time{}, time.Time{}, "1 MINUTE", 10)
+	assert.Empty(t, resp.Buckets)
+	assert.Empty(t, resp.Rows)
+	assert.NotNil(t, resp.Rows)
+}
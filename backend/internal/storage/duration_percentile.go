@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// GetDurationPercentile ranks durationMS among the entries of a job with
+// the same log type whose scope column, "form" or "sql_table", equals
+// value. The rank counts ties as half below, so a duration shared by every
+// entry sits at the 50th percentile. Results of complete jobs are cached.
+func (c *ClickHouseClient) GetDurationPercentile(ctx context.Context, tenantID, jobID string, logType domain.LogType, scope, value string, durationMS uint32) (*domain.DurationPercentile, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	if scope != "form" && scope != "sql_table" {
+		return nil, fmt.Errorf("clickhouse: duration percentile: unknown scope %q", scope)
+	}
+	ctx = c.cacheReads(ctx, tenantID, jobID)
+
+	var samples, below, equal uint64
+	var quantiles []float64
+	err := c.conn.QueryRow(ctx, `
+		SELECT
+			count(),
+			countIf(duration_ms < @duration),
+			countIf(duration_ms = @duration),
+			quantiles(0.5, 0.95, 0.99)(duration_ms)
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+		  AND log_type = @logType AND `+scope+` = @value`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("logType", string(logType)),
+		clickhouse.Named("value", value),
+		clickhouse.Named("duration", durationMS),
+	).Scan(&samples, &below, &equal, &quantiles)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: duration percentile: %w", err)
+	}
+
+	p := &domain.DurationPercentile{
+		Scope:      scope,
+		Value:      value,
+		LogType:    logType,
+		DurationMS: durationMS,
+		Samples:    int64(samples),
+	}
+	if samples > 0 {
+		p.Percentile = (float64(below) + float64(equal)/2) / float64(samples) * 100
+	}
+	if len(quantiles) == 3 {
+		p.P50MS, p.P95MS, p.P99MS = quantiles[0], quantiles[1], quantiles[2]
+	}
+	return p, nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestGetDurationPercentile(t *testing.T) {
+	conn := &fakeConn{row: []any{uint64(200), uint64(190), uint64(4), []float64{12, 800, 2400}}}
+	p, err := (&ClickHouseClient{conn: conn}).GetDurationPercentile(context.Background(), testTenantID, testJobID, domain.LogTypeSQL, "sql_table", "T4381", 1500)
+	require.NoError(t, err)
+	assert.InDelta(t, 96, p.Percentile, 0.001, "ties count as half below")
+	assert.Equal(t, int64(200), p.Samples)
+	assert.Equal(t, 800.0, p.P95MS)
+	assert.Equal(t, "T4381", p.Value)
+
+	conn = &fakeConn{row: []any{uint64(0), uint64(0), uint64(0), []float64{0, 0, 0}}}
+	p, err = (&ClickHouseClient{conn: conn}).GetDurationPercentile(context.Background(), testTenantID, testJobID, domain.LogTypeAPI, "form", "HPD:Help Desk", 10)
+	require.NoError(t, err)
+	assert.Zero(t, p.Percentile)
+
+	_, err = (&ClickHouseClient{conn: conn}).GetDurationPercentile(context.Background(), testTenantID, testJobID, domain.LogTypeAPI, "raw_text", "x", 10)
+	assert.ErrorContains(t, err, "unknown scope")
+}
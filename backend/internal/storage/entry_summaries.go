@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// entrySummaryTextChars is how much of the raw text and error message of
+// an entry a summary reads.
+const entrySummaryTextChars = 300
+
+// GetEntrySummaries returns a summary of each of the entries of a job
+// named by entryIDs, in one query: where the entry is and what it logged,
+// with RawText and ErrorMessage cut to their first characters. Entries the
+// job does not hold are left out; the order is unspecified. Results are
+// tenant-scoped.
+func (c *ClickHouseClient) GetEntrySummaries(ctx context.Context, tenantID, jobID string, entryIDs []string) ([]domain.LogEntry, error) {
+	if len(entryIDs) == 0 {
+		return nil, nil
+	}
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	rows, err := c.conn.Query(ctx, `
+		SELECT
+			entry_id, line_number, file_number, timestamp, log_type,
+			queue, form, duration_ms, success,
+			leftUTF8(raw_text, @textChars), leftUTF8(error_message, @textChars)
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND entry_id IN (@entryIDs)
+		LIMIT 1 BY entry_id
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("entryIDs", entryIDs),
+		clickhouse.Named("textChars", entrySummaryTextChars),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: entry summaries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]domain.LogEntry, 0, len(entryIDs))
+	for rows.Next() {
+		e := domain.LogEntry{TenantID: tenantID, JobID: jobID}
+		var logType string
+		if err := rows.Scan(
+			&e.EntryID, &e.LineNumber, &e.FileNumber, &e.Timestamp, &logType,
+			&e.Queue, &e.Form, &e.DurationMS, &e.Success,
+			&e.RawText, &e.ErrorMessage,
+		); err != nil {
+			return nil, fmt.Errorf("clickhouse: entry summaries scan: %w", err)
+		}
+		e.LogType = domain.LogType(logType)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: entry summaries rows: %w", err)
+	}
+	return entries, nil
+}
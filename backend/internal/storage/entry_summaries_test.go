@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestGetEntrySummaries(t *testing.T) {
+	ts := time.Date(2026, 3, 2, 9, 12, 0, 0, time.UTC)
+	conn := &fakeConn{rows: [][]any{
+		{"e2", uint32(40), uint16(1), ts, "SQL", "Fast", "", uint32(12), true, "SELECT * FROM T1", ""},
+		{"e1", uint32(12), uint16(1), ts, "API", "Fast", "HPD:Help Desk", uint32(900), false, "+GE", "ARERR 302"},
+	}}
+	c := &ClickHouseClient{conn: conn}
+
+	entries, err := c.GetEntrySummaries(context.Background(), testTenantID, testJobID, []string{"e1", "e2", "gone"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "e1", entries[1].EntryID)
+	assert.Equal(t, domain.LogTypeAPI, entries[1].LogType)
+	assert.Equal(t, "HPD:Help Desk", entries[1].Form)
+	assert.Equal(t, "ARERR 302", entries[1].ErrorMessage)
+	assert.Equal(t, testJobID, entries[1].JobID)
+
+	assert.Equal(t, 1, conn.queries, "one query for every pin")
+	assert.Contains(t, conn.lastQuery, "entry_id IN (@entryIDs)")
+	assert.Contains(t, conn.lastQuery, "LIMIT 1 BY entry_id")
+	assert.Contains(t, conn.lastArgs, clickhouse.Named("entryIDs", []string{"e1", "e2", "gone"}))
+}
+
+func TestGetEntrySummaries_NoIDs(t *testing.T) {
+	conn := &fakeConn{}
+	entries, err := (&ClickHouseClient{conn: conn}).GetEntrySummaries(context.Background(), testTenantID, testJobID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Zero(t, conn.queries)
+}
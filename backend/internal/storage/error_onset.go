@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// ErrorRateThresholds are the trailing error rates whose first crossing an
+// error onset reports.
+var ErrorRateThresholds = []float64{0.001, 0.01, 0.05}
+
+const (
+	// errorOnsetBuckets is the number of buckets the capture window is cut
+	// into for the onset curve; buckets are at least a second long.
+	errorOnsetBuckets = 240
+
+	// errorOnsetWindowBuckets is the length of the trailing window the
+	// error rate is measured over, in buckets.
+	errorOnsetWindowBuckets = 10
+
+	// minOnsetWindowEntries is the fewest entries a trailing window must
+	// hold for its error rate to count, so that a single early error in a
+	// quiet window is not reported as a crossing.
+	minOnsetWindowEntries = 20
+
+	// maxFirstErrors caps the forms and queues, earliest first, whose first
+	// error is reported.
+	maxFirstErrors = 50
+)
+
+// errorOnsetBucket is one non-empty bucket of the capture window, Index
+// buckets after its start.
+type errorOnsetBucket struct {
+	Index            int64
+	Entries          int64
+	Errors           int64
+	CumulativeErrors int64
+}
+
+// GetErrorOnset computes the error onset of a job: the first failed entry
+// per log type, form and queue, then the per-bucket entry and error counts
+// with their running error total, from which the onset curve and threshold
+// crossings are derived in Go.
+func (c *ClickHouseClient) GetErrorOnset(ctx context.Context, tenantID, jobID string) (*domain.ErrorOnset, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	firstErrorColumns := `
+		min(timestamp) AS first_ts,
+		argMin(entry_id, (timestamp, file_number, line_number)) AS first_entry_id,
+		argMin(line_number, (timestamp, file_number, line_number)) AS first_line,
+		argMin(file_number, (timestamp, file_number, line_number)) AS first_file,
+		count() AS errors`
+	failed := "tenant_id = @tenantID AND job_id = @jobID AND success = false"
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT dimension, key, first_ts, first_entry_id, first_line, first_file, errors
+		FROM (
+			SELECT 'log_type' AS dimension, toString(log_type) AS key, %[1]s
+			FROM log_entries WHERE %[2]s GROUP BY key
+			UNION ALL
+			SELECT 'form' AS dimension, form AS key, %[1]s
+			FROM log_entries WHERE %[2]s AND form != '' GROUP BY key
+			UNION ALL
+			SELECT 'queue' AS dimension, queue AS key, %[1]s
+			FROM log_entries WHERE %[2]s AND queue != '' GROUP BY key
+		)
+		ORDER BY dimension, first_ts, key
+		LIMIT %[3]d BY dimension
+	`, firstErrorColumns, failed, maxFirstErrors),
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: error onset first errors: %w", err)
+	}
+	defer rows.Close()
+
+	onset := &domain.ErrorOnset{
+		JobID:     jobID,
+		ByLogType: []domain.FirstError{},
+		ByForm:    []domain.FirstError{},
+		ByQueue:   []domain.FirstError{},
+	}
+	for rows.Next() {
+		var dimension string
+		var fe domain.FirstError
+		var errs uint64
+		if err := rows.Scan(&dimension, &fe.Key, &fe.Timestamp, &fe.EntryID, &fe.LineNumber, &fe.FileNumber, &errs); err != nil {
+			return nil, fmt.Errorf("clickhouse: error onset first errors scan: %w", err)
+		}
+		fe.ErrorCount = int64(errs)
+		switch dimension {
+		case "log_type":
+			onset.ByLogType = append(onset.ByLogType, fe)
+		case "form":
+			onset.ByForm = append(onset.ByForm, fe)
+		case "queue":
+			onset.ByQueue = append(onset.ByQueue, fe)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: error onset first errors rows: %w", err)
+	}
+
+	seriesRows, err := c.queryErrorOnsetSeries(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: error onset series: %w", err)
+	}
+	defer seriesRows.Close()
+
+	var buckets []errorOnsetBucket
+	for seriesRows.Next() {
+		var b errorOnsetBucket
+		var entries, errs, cumulative uint64
+		if err := seriesRows.Scan(&b.Index, &entries, &errs, &cumulative, &onset.CaptureStart, &onset.CaptureEnd, &onset.BucketMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: error onset series scan: %w", err)
+		}
+		b.Entries, b.Errors, b.CumulativeErrors = int64(entries), int64(errs), int64(cumulative)
+		buckets = append(buckets, b)
+	}
+	if err := seriesRows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: error onset series rows: %w", err)
+	}
+
+	buildErrorOnset(onset, buckets)
+	if onset.Estimate, err = c.sampleEstimate(ctx, tenantID, jobID); err != nil {
+		return nil, fmt.Errorf("clickhouse: error onset: %w", err)
+	}
+	return onset, nil
+}
+
+// queryErrorOnsetSeries returns the non-empty buckets of a job's capture
+// window with their running error total, and the window itself. Buckets of
+// a minute or more are summed from the rollup: they are then rounded up to
+// whole minutes, and each minute counts in the bucket its start falls in,
+// so counts may shift by up to a minute into an earlier bucket.
+func (c *ClickHouseClient) queryErrorOnsetSeries(ctx context.Context, tenantID, jobID string) (driver.Rows, error) {
+	if c.hasRollup(ctx, tenantID, jobID) {
+		first, last, err := c.rollupSpan(ctx, tenantID, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if bucketMS := errorOnsetBucketMS(first, last); bucketMS >= time.Minute.Milliseconds() {
+			minute := time.Minute.Milliseconds()
+			return c.conn.Query(ctx, `
+				SELECT
+					bucket, entries, errors,
+					sum(errors) OVER (ORDER BY bucket ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS cumulative_errors,
+					capture_start, capture_end, bucket_ms
+				FROM (
+					WITH
+						toDateTime64(@captureStart, 3) AS cap_start,
+						toDateTime64(@captureEnd, 3) AS cap_end,
+						toInt64(@bucketMS) AS b_ms
+					SELECT
+						intDiv(greatest(toUnixTimestamp64Milli(toDateTime64(minute, 3)), toUnixTimestamp64Milli(cap_start)) - toUnixTimestamp64Milli(cap_start), b_ms) AS bucket,
+						sum(entries) AS entries,
+						sum(errors) AS errors,
+						any(cap_start) AS capture_start,
+						any(cap_end) AS capture_end,
+						any(b_ms) AS bucket_ms
+					FROM `+rollupTable+`
+					WHERE tenant_id = @tenantID AND job_id = @jobID
+					GROUP BY bucket
+				)
+				ORDER BY bucket
+			`,
+				clickhouse.Named("tenantID", tenantID),
+				clickhouse.Named("jobID", jobID),
+				clickhouse.Named("captureStart", first.UTC().Format("2006-01-02 15:04:05.000")),
+				clickhouse.Named("captureEnd", last.UTC().Format("2006-01-02 15:04:05.000")),
+				clickhouse.Named("bucketMS", (bucketMS+minute-1)/minute*minute),
+			)
+		}
+	}
+
+	// Buckets are counted from the first entry of the capture. The +1 keeps
+	// the last entry inside the last bucket.
+	return c.conn.Query(ctx, `
+		SELECT
+			bucket, entries, errors,
+			sum(errors) OVER (ORDER BY bucket ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS cumulative_errors,
+			capture_start, capture_end, bucket_ms
+		FROM (
+			WITH
+				(SELECT min(timestamp) FROM log_entries WHERE tenant_id = @tenantID AND job_id = @jobID) AS cap_start,
+				(SELECT max(timestamp) FROM log_entries WHERE tenant_id = @tenantID AND job_id = @jobID) AS cap_end,
+				greatest(toInt64(1000), toInt64(ceil((toUnixTimestamp64Milli(cap_end) - toUnixTimestamp64Milli(cap_start) + 1) / @buckets))) AS b_ms
+			SELECT
+				intDiv(toUnixTimestamp64Milli(timestamp) - toUnixTimestamp64Milli(cap_start), b_ms) AS bucket,
+				sum(sample_weight) AS entries,
+				sumIf(sample_weight, success = false) AS errors,
+				any(cap_start) AS capture_start,
+				any(cap_end) AS capture_end,
+				any(b_ms) AS bucket_ms
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID
+			GROUP BY bucket
+		)
+		ORDER BY bucket
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("buckets", errorOnsetBuckets),
+	)
+}
+
+// errorOnsetBucketMS is the bucket length, in milliseconds, that cuts the
+// capture window from first to last into errorOnsetBuckets buckets of at
+// least a second, as the onset series query computes it.
+func errorOnsetBucketMS(first, last time.Time) int64 {
+	span := last.Sub(first).Milliseconds() + 1
+	return max(1000, (span+errorOnsetBuckets-1)/errorOnsetBuckets)
+}
+
+// buildErrorOnset fills the totals, overall first error, onset curve and
+// threshold crossings of onset from its first errors by log type and the
+// non-empty buckets of its capture window, in index order. CaptureStart,
+// CaptureEnd and BucketMS must already be set.
+func buildErrorOnset(onset *domain.ErrorOnset, buckets []errorOnsetBucket) {
+	onset.ComputedAt = domain.NewTimestamp(time.Now().UTC())
+	onset.WindowMS = onset.BucketMS * errorOnsetWindowBuckets
+	onset.Curve = []domain.ErrorOnsetPoint{}
+	onset.Thresholds = make([]domain.ErrorRateCrossing, len(ErrorRateThresholds))
+	for i, threshold := range ErrorRateThresholds {
+		onset.Thresholds[i].Threshold = threshold
+	}
+
+	onset.TotalEntries, onset.TotalErrors = 0, 0
+	for _, b := range buckets {
+		onset.TotalEntries += b.Entries
+		onset.TotalErrors += b.Errors
+	}
+
+	onset.FirstError = nil
+	for i := range onset.ByLogType {
+		if fe := onset.ByLogType[i]; onset.FirstError == nil || fe.Timestamp.Before(onset.FirstError.Timestamp.Time) {
+			onset.FirstError = &fe
+		}
+	}
+	if onset.FirstError != nil {
+		onset.FirstError.ErrorCount = onset.TotalErrors
+	}
+
+	if len(buckets) == 0 || onset.BucketMS <= 0 {
+		return
+	}
+	onset.Curve = errorOnsetCurve(onset, buckets)
+
+	bucketStart := func(index int64) time.Time {
+		return onset.CaptureStart.Add(time.Duration(index*onset.BucketMS) * time.Millisecond)
+	}
+	for _, w := range trailingErrorRates(buckets, errorOnsetWindowBuckets) {
+		if w.Entries < minOnsetWindowEntries {
+			continue
+		}
+		rate := float64(w.Errors) / float64(w.Entries)
+		for i := range onset.Thresholds {
+			crossing := &onset.Thresholds[i]
+			if crossing.CrossedAt != nil || rate <= crossing.Threshold {
+				continue
+			}
+			at := bucketStart(w.Index)
+			crossing.CrossedAt = domain.TimestampPtr(&at)
+			crossing.Rate = rate
+			crossing.WindowEntries = w.Entries
+			crossing.WindowErrors = w.Errors
+		}
+	}
+}
+
+// errorOnsetCurve returns the cumulative error count at the start of the
+// capture and at the end of every bucket, empty buckets included.
+func errorOnsetCurve(onset *domain.ErrorOnset, buckets []errorOnsetBucket) []domain.ErrorOnsetPoint {
+	captureStart, captureEnd := onset.CaptureStart.Time, onset.CaptureEnd.Time
+	span := captureEnd.Sub(captureStart)
+	point := func(at time.Time, cumulative int64) domain.ErrorOnsetPoint {
+		if at.After(captureEnd) {
+			at = captureEnd
+		}
+		p := domain.ErrorOnsetPoint{Timestamp: domain.NewTimestamp(at), CumulativeErrors: cumulative, Offset: 1}
+		if span > 0 {
+			p.Offset = float64(at.Sub(captureStart)) / float64(span)
+		}
+		if onset.TotalErrors > 0 {
+			p.Fraction = float64(cumulative) / float64(onset.TotalErrors)
+		}
+		return p
+	}
+
+	last := buckets[len(buckets)-1].Index
+	curve := make([]domain.ErrorOnsetPoint, 0, last+2)
+	curve = append(curve, point(captureStart, 0))
+	var cumulative int64
+	next := 0
+	for i := int64(0); i <= last; i++ {
+		if next < len(buckets) && buckets[next].Index == i {
+			cumulative = buckets[next].CumulativeErrors
+			next++
+		}
+		end := captureStart.Add(time.Duration((i+1)*onset.BucketMS) * time.Millisecond)
+		curve = append(curve, point(end, cumulative))
+	}
+	return curve
+}
+
+// trailingErrorRates returns, for each non-empty bucket, the entries and
+// errors of the window of windowBuckets buckets ending with it. Buckets
+// must be in index order; empty buckets may be missing.
+func trailingErrorRates(buckets []errorOnsetBucket, windowBuckets int64) []errorOnsetBucket {
+	windows := make([]errorOnsetBucket, 0, len(buckets))
+	var entries, errs int64
+	first := 0
+	for _, b := range buckets {
+		entries += b.Entries
+		errs += b.Errors
+		for buckets[first].Index <= b.Index-windowBuckets {
+			entries -= buckets[first].Entries
+			errs -= buckets[first].Errors
+			first++
+		}
+		windows = append(windows, errorOnsetBucket{Index: b.Index, Entries: entries, Errors: errs})
+	}
+	return windows
+}
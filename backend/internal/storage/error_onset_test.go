@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var onsetStart = time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+
+// onsetSeries returns one bucket per element of entries and errors, with
+// the running error total ClickHouse would compute. Buckets with no
+// entries are left out, as ClickHouse would.
+func onsetSeries(entries, errors []int64) []errorOnsetBucket {
+	var buckets []errorOnsetBucket
+	var cumulative int64
+	for i := range entries {
+		cumulative += errors[i]
+		if entries[i] == 0 {
+			continue
+		}
+		buckets = append(buckets, errorOnsetBucket{Index: int64(i), Entries: entries[i], Errors: errors[i], CumulativeErrors: cumulative})
+	}
+	return buckets
+}
+
+// newOnset returns an onset whose capture window covers n buckets of a
+// second.
+func newOnset(n int) *domain.ErrorOnset {
+	return &domain.ErrorOnset{
+		CaptureStart: domain.NewTimestamp(onsetStart),
+		CaptureEnd:   domain.NewTimestamp(onsetStart.Add(time.Duration(n)*time.Second - time.Millisecond)),
+		BucketMS:     1000,
+	}
+}
+
+func repeat(v int64, n int) []int64 {
+	s := make([]int64, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func crossing(t *testing.T, onset *domain.ErrorOnset, threshold float64) domain.ErrorRateCrossing {
+	t.Helper()
+	for _, c := range onset.Thresholds {
+		if c.Threshold == threshold {
+			return c
+		}
+	}
+	t.Fatalf("no crossing for threshold %v", threshold)
+	return domain.ErrorRateCrossing{}
+}
+
+func TestTrailingErrorRates(t *testing.T) {
+	buckets := []errorOnsetBucket{
+		{Index: 0, Entries: 10, Errors: 1},
+		{Index: 1, Entries: 10, Errors: 2},
+		{Index: 5, Entries: 10, Errors: 3}, // buckets 2-4 are empty
+		{Index: 6, Entries: 10, Errors: 4},
+	}
+	windows := trailingErrorRates(buckets, 3)
+	require.Len(t, windows, 4)
+
+	assert.Equal(t, errorOnsetBucket{Index: 0, Entries: 10, Errors: 1}, windows[0])
+	assert.Equal(t, errorOnsetBucket{Index: 1, Entries: 20, Errors: 3}, windows[1])
+	assert.Equal(t, errorOnsetBucket{Index: 5, Entries: 10, Errors: 3}, windows[2], "buckets 0 and 1 have left the window")
+	assert.Equal(t, errorOnsetBucket{Index: 6, Entries: 20, Errors: 7}, windows[3])
+}
+
+func TestBuildErrorOnset(t *testing.T) {
+	t.Run("capture with zero errors", func(t *testing.T) {
+		onset := newOnset(60)
+		buildErrorOnset(onset, onsetSeries(repeat(100, 60), repeat(0, 60)))
+
+		assert.Nil(t, onset.FirstError)
+		assert.Equal(t, int64(6000), onset.TotalEntries)
+		assert.Zero(t, onset.TotalErrors)
+		require.Len(t, onset.Thresholds, len(ErrorRateThresholds))
+		for _, c := range onset.Thresholds {
+			assert.Nil(t, c.CrossedAt, "threshold %v", c.Threshold)
+		}
+		require.Len(t, onset.Curve, 61)
+		for _, p := range onset.Curve {
+			assert.Zero(t, p.CumulativeErrors)
+			assert.Zero(t, p.Fraction)
+		}
+	})
+
+	t.Run("capture that starts already failing", func(t *testing.T) {
+		errTime := onsetStart.Add(20 * time.Millisecond)
+		onset := newOnset(30)
+		onset.ByLogType = []domain.FirstError{
+			{Key: "SQL", Timestamp: domain.NewTimestamp(errTime.Add(time.Second)), EntryID: "sql-1", ErrorCount: 30},
+			{Key: "API", Timestamp: domain.NewTimestamp(errTime), EntryID: "api-1", LineNumber: 3, ErrorCount: 270},
+		}
+		buildErrorOnset(onset, onsetSeries(repeat(100, 30), repeat(10, 30)))
+
+		require.NotNil(t, onset.FirstError)
+		assert.Equal(t, "api-1", onset.FirstError.EntryID)
+		assert.Equal(t, "API", onset.FirstError.Key)
+		assert.Equal(t, int64(300), onset.FirstError.ErrorCount, "overall first error counts every error")
+
+		for _, threshold := range ErrorRateThresholds {
+			c := crossing(t, onset, threshold)
+			require.NotNil(t, c.CrossedAt, "threshold %v", threshold)
+			assert.Equal(t, onsetStart, c.CrossedAt.Time)
+			assert.InDelta(t, 0.1, c.Rate, 1e-9)
+			assert.Equal(t, int64(100), c.WindowEntries)
+			assert.Equal(t, int64(10), c.WindowErrors)
+		}
+	})
+
+	t.Run("errors ramp up part way through", func(t *testing.T) {
+		// 1000 entries a second; no errors for 20s, then 2 errors a second
+		// (0.2%), then from 40s 30 a second (3%).
+		errs := append(append(repeat(0, 20), repeat(2, 20)...), repeat(30, 20)...)
+		onset := newOnset(60)
+		buildErrorOnset(onset, onsetSeries(repeat(1000, 60), errs))
+
+		low := crossing(t, onset, 0.001)
+		require.NotNil(t, low.CrossedAt)
+		// The 10s window first exceeds 0.1% once it holds more than 10 errors.
+		assert.Equal(t, onsetStart.Add(25*time.Second), low.CrossedAt.Time)
+
+		mid := crossing(t, onset, 0.01)
+		require.NotNil(t, mid.CrossedAt)
+		// At 42s the window holds 7×2 + 3×30 = 104 errors in 10000 entries.
+		assert.Equal(t, onsetStart.Add(42*time.Second), mid.CrossedAt.Time)
+		assert.Greater(t, mid.Rate, 0.01)
+
+		assert.Nil(t, crossing(t, onset, 0.05).CrossedAt, "the rate never exceeds 5%")
+	})
+
+	t.Run("quiet windows are not evaluated", func(t *testing.T) {
+		entries := append(repeat(1, 5), repeat(1000, 10)...)
+		errs := append([]int64{1, 0, 0, 0, 0}, repeat(0, 10)...)
+		onset := newOnset(15)
+		buildErrorOnset(onset, onsetSeries(entries, errs))
+
+		// One error in the first five entries is a 20% rate, but too few
+		// entries; once the window is busy the rate is below 0.1%.
+		for _, c := range onset.Thresholds {
+			assert.Nil(t, c.CrossedAt, "threshold %v", c.Threshold)
+		}
+	})
+
+	t.Run("curve is normalized to the capture window", func(t *testing.T) {
+		entries := []int64{10, 0, 0, 10}
+		errs := []int64{1, 0, 0, 3}
+		onset := newOnset(4)
+		buildErrorOnset(onset, onsetSeries(entries, errs))
+
+		require.Len(t, onset.Curve, 5, "the start plus every bucket, empty ones included")
+		assert.Equal(t, domain.ErrorOnsetPoint{Timestamp: domain.NewTimestamp(onsetStart), Offset: 0, CumulativeErrors: 0, Fraction: 0}, onset.Curve[0])
+		assert.Equal(t, int64(1), onset.Curve[1].CumulativeErrors)
+		assert.Equal(t, int64(1), onset.Curve[2].CumulativeErrors)
+		assert.Equal(t, int64(1), onset.Curve[3].CumulativeErrors)
+		assert.InDelta(t, 0.25, onset.Curve[3].Fraction, 1e-9)
+
+		end := onset.Curve[4]
+		assert.Equal(t, int64(4), end.CumulativeErrors)
+		assert.Equal(t, onset.CaptureEnd, end.Timestamp, "the last point is clamped to the capture end")
+		assert.InDelta(t, 1.0, end.Offset, 1e-9)
+		assert.InDelta(t, 1.0, end.Fraction, 1e-9)
+		assert.Equal(t, int64(10_000), onset.WindowMS)
+	})
+
+	t.Run("empty capture", func(t *testing.T) {
+		onset := &domain.ErrorOnset{}
+		buildErrorOnset(onset, nil)
+		assert.Nil(t, onset.FirstError)
+		assert.Empty(t, onset.Curve)
+		assert.Len(t, onset.Thresholds, len(ErrorRateThresholds))
+	})
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FilesystemStorage keeps objects as files under a root directory. It needs
+// no external service, which makes it the backend of choice for development
+// and tests. It cannot pre-sign URLs; objects are served through the API.
+type FilesystemStorage struct {
+	root string
+}
+
+// NewFilesystemStorage creates a filesystem backend rooted at root, creating
+// the directory if needed.
+func NewFilesystemStorage(root string) (*FilesystemStorage, error) {
+	if root == "" {
+		return nil, fmt.Errorf("filesystem: root directory is required")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("filesystem: resolve root %q: %w", root, err)
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, fmt.Errorf("filesystem: create root %q: %w", abs, err)
+	}
+	return &FilesystemStorage{root: abs}, nil
+}
+
+// path maps an object key to a file below the root, rejecting keys that
+// would escape it.
+func (f *FilesystemStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || strings.Contains(key, "\\") || clean != "/"+key {
+		return "", fmt.Errorf("filesystem: invalid key %q", key)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(clean)), nil
+}
+
+// Upload writes the object to a temporary file next to its destination and
+// renames it into place, so readers never see a partial object. size is
+// only used to verify the number of bytes written when it is not negative.
+func (f *FilesystemStorage) Upload(ctx context.Context, key string, reader io.Reader, size int64) error {
+	dst, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return fmt.Errorf("filesystem: upload %q: %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("filesystem: upload %q: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, readerWithContext(ctx, reader))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("filesystem: upload %q: %w", key, err)
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("filesystem: upload %q: wrote %d bytes, expected %d", key, n, size)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("filesystem: upload %q: %w", key, err)
+	}
+	return nil
+}
+
+// Download opens the object at key. The caller must close the reader.
+func (f *FilesystemStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("filesystem: download %q: %w", key, err)
+	}
+	return file, nil
+}
+
+// Delete removes the object at key. Deleting a missing object is not an
+// error, as with S3.
+func (f *FilesystemStorage) Delete(ctx context.Context, key string) error {
+	p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("filesystem: delete %q: %w", key, err)
+	}
+	return nil
+}
+
+// PresignGetURL always returns ErrPresignUnsupported.
+func (f *FilesystemStorage) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+// Exists reports whether an object is stored at key.
+func (f *FilesystemStorage) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := f.path(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("filesystem: stat %q: %w", key, err)
+	}
+	return info.Mode().IsRegular(), nil
+}
+
+// ctxReader stops a copy once its context is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func readerWithContext(ctx context.Context, r io.Reader) io.Reader {
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestGetFilterComplexity(t *testing.T) {
+	totals := []any{
+		int64(6100), int64(10000),
+		[]string{"SRM:Approve", "HPD:Assign", "HPD:Notify"},
+		[]int64{40, 300, 40},
+		[]int64{900, 5000, 200},
+	}
+	forms := [][]any{
+		{"SRM:Request", int64(6100), int64(10000)},
+		{"SRM:Batch", int64(500), int64(0)},
+	}
+
+	t.Run("summary", func(t *testing.T) {
+		conn := &fakeConn{results: [][][]any{{totals}, forms}}
+		resp, err := (&ClickHouseClient{conn: conn}).GetFilterComplexity(context.Background(), testTenantID, testJobID, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, conn.queries, "no per-transaction query without detail")
+
+		assert.Equal(t, []domain.MostExecutedFilter{
+			{Name: "HPD:Assign", Count: 300, TotalMS: 5000},
+			{Name: "HPD:Notify", Count: 40, TotalMS: 200},
+			{Name: "SRM:Approve", Count: 40, TotalMS: 900},
+		}, resp.MostExecuted)
+		assert.Equal(t, int64(6100), resp.TotalFilterTimeMS)
+		assert.Empty(t, resp.PerTransaction)
+
+		require.NotNil(t, resp.TimeShare.SharePct)
+		assert.InDelta(t, 61, *resp.TimeShare.SharePct, 0.001)
+		require.Len(t, resp.FormTimeShare, 2)
+		assert.Equal(t, "SRM:Request", resp.FormTimeShare[0].Form)
+		assert.InDelta(t, 61, *resp.FormTimeShare[0].SharePct, 0.001)
+		assert.Nil(t, resp.FormTimeShare[1].SharePct, "no share without API time")
+	})
+
+	t.Run("detail", func(t *testing.T) {
+		conn := &fakeConn{
+			results: [][][]any{{totals}, forms},
+			rows:    [][]any{{"trace-1", "HPD:Assign", 3, int64(90), 30.0, int64(50), "Fast", "HPD:Help Desk"}},
+		}
+		resp, err := (&ClickHouseClient{conn: conn}).GetFilterComplexity(context.Background(), testTenantID, testJobID, true)
+		require.NoError(t, err)
+		assert.Equal(t, 3, conn.queries)
+		require.Len(t, resp.PerTransaction, 1)
+		assert.Equal(t, "trace-1", resp.PerTransaction[0].TransactionID)
+	})
+
+	t.Run("empty job", func(t *testing.T) {
+		conn := &fakeConn{results: [][][]any{{{int64(0), int64(0), []string{}, []int64{}, []int64{}}}, {}}}
+		resp, err := (&ClickHouseClient{conn: conn}).GetFilterComplexity(context.Background(), testTenantID, testJobID, false)
+		require.NoError(t, err)
+		assert.Empty(t, resp.MostExecuted)
+		assert.Nil(t, resp.TimeShare.SharePct)
+		assert.NotNil(t, resp.FormTimeShare)
+	})
+}
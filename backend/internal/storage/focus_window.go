@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// GetFocusMetrics returns the entries, errors, p95 duration and average API
+// queue time of every non-empty bucket of a job's capture between from and
+// to, bucketed as the histogram is, for scoring its focus window.
+func (c *ClickHouseClient) GetFocusMetrics(ctx context.Context, tenantID, jobID string, from, to time.Time) (*domain.FocusMetrics, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	bucketSize := computeBucketSize(from, to)
+
+	// bucketSize comes from computeBucketSize, never from user input.
+	query := fmt.Sprintf(`
+		SELECT
+			toStartOfInterval(timestamp, INTERVAL %s) AS bucket,
+			sum(sample_weight) AS entries,
+			sumIf(sample_weight, success = false) AS errors,
+			toFloat64(quantileExactWeighted(0.95)(duration_ms, sample_weight)) AS p95_ms,
+			if(sumIf(sample_weight, log_type = 'API') > 0,
+				sumIf(queue_time_ms * sample_weight, log_type = 'API') / sumIf(sample_weight, log_type = 'API'),
+				0) AS avg_queue_ms
+		FROM log_entries
+		WHERE tenant_id = {tenant_id:String}
+		  AND job_id = {job_id:String}
+		  AND timestamp >= toDateTime64({time_from:String}, 3)
+		  AND timestamp <= toDateTime64({time_to:String}, 3)
+		GROUP BY bucket
+		ORDER BY bucket
+	`, bucketSize)
+	if intervalDuration(bucketSize) >= time.Minute && c.hasRollup(ctx, tenantID, jobID) {
+		query = fmt.Sprintf(`
+			SELECT
+				toStartOfInterval(minute, INTERVAL %s) AS bucket,
+				sum(entries) AS entries,
+				sum(errors) AS errors,
+				toFloat64(quantileExactWeightedMerge(0.95)(p95_duration_ms)) AS p95_ms,
+				if(sumIf(entries, log_type = 'API') > 0,
+					sumIf(queue_time_ms_weighted, log_type = 'API') / sumIf(entries, log_type = 'API'),
+					0) AS avg_queue_ms
+			FROM `+rollupTable+`
+			WHERE tenant_id = {tenant_id:String}
+			  AND job_id = {job_id:String}
+			  AND minute >= toStartOfMinute(toDateTime64({time_from:String}, 3))
+			  AND minute <= toDateTime64({time_to:String}, 3)
+			GROUP BY bucket
+			ORDER BY bucket
+		`, bucketSize)
+	}
+	rows, err := c.conn.Query(ctx, query,
+		clickhouse.Named("tenant_id", tenantID),
+		clickhouse.Named("job_id", jobID),
+		clickhouse.Named("time_from", from.UTC().Format("2006-01-02 15:04:05.000")),
+		clickhouse.Named("time_to", to.UTC().Format("2006-01-02 15:04:05.000")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: focus metrics query: %w", err)
+	}
+	defer rows.Close()
+
+	metrics := &domain.FocusMetrics{
+		RangeStart: domain.NewTimestamp(from),
+		RangeEnd:   domain.NewTimestamp(to),
+		BucketSize: bucketSize,
+		BucketMS:   intervalDuration(bucketSize).Milliseconds(),
+		Buckets:    []domain.FocusBucket{},
+	}
+	for rows.Next() {
+		var b domain.FocusBucket
+		var entries, errs uint64
+		if err := rows.Scan(&b.Start, &entries, &errs, &b.P95MS, &b.AvgQueueMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: focus metrics scan: %w", err)
+		}
+		b.Entries, b.Errors = int64(entries), int64(errs)
+		metrics.Buckets = append(metrics.Buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: focus metrics rows: %w", err)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// gapContextWindow bounds how far before a gap threads count as active
+	// and how far after it queue times are sampled.
+	gapContextWindow = 60 * time.Second
+
+	// gapStallSkew is how close to the gap start every active thread must
+	// have gone quiet for the silence to count as simultaneous.
+	gapStallSkew = time.Second
+
+	// gapQueueBurstMinMS is the smallest post-gap average queue time that can
+	// count as a burst of queued work, whatever the baseline.
+	gapQueueBurstMinMS = 100
+
+	// gapQueueBurstFactor is how many times the job-wide average queue time
+	// the post-gap average must reach to count as a burst.
+	gapQueueBurstFactor = 2.0
+
+	// maxGapEvidenceEntries caps the pre-gap entries attached to a hint.
+	maxGapEvidenceEntries = 10
+)
+
+// gapContext is the data a gap is classified from.
+type gapContext struct {
+	beforeFile int
+	afterFile  int
+	pre        []domain.GapThreadEntry // newest first
+	post       domain.GapQueueTimeStats
+}
+
+// classifyGap derives a root-cause hint for a gap. The rules are applied in
+// order and the first match wins:
+//
+//  1. log_rotation: the entries on either side of the gap come from different
+//     files, or the line number after the gap is lower than before it. The
+//     silence is the hand-off between log files, not server behaviour.
+//  2. unknown: no thread logged anything within gapContextWindow before the
+//     gap, so there is nothing to correlate.
+//  3. single_thread_block: exactly one thread was active before the gap. The
+//     server was otherwise idle and that thread's last call is the suspect.
+//  4. server_stall: several threads across more than one queue all went
+//     quiet within gapStallSkew of the gap start. A server-wide pause such
+//     as a database outage or a full GC is the usual cause.
+//  5. queue_starvation: several threads of a single queue went quiet and the
+//     API calls after the gap waited in the queue at least
+//     gapQueueBurstFactor times the job average (and gapQueueBurstMinMS).
+//     The queue's thread pool was exhausted while work piled up.
+//  6. unknown: anything else.
+func classifyGap(gap domain.GapEntry, gc gapContext) domain.GapHint {
+	hint := domain.GapHint{
+		Classification:   domain.GapUnknown,
+		ThreadIDs:        []string{},
+		PreGapEntries:    gc.pre,
+		PostGapQueueTime: gc.post,
+	}
+	if hint.PreGapEntries == nil {
+		hint.PreGapEntries = []domain.GapThreadEntry{}
+	}
+	if len(hint.PreGapEntries) > maxGapEvidenceEntries {
+		hint.PreGapEntries = hint.PreGapEntries[:maxGapEvidenceEntries]
+	}
+
+	queues := make(map[string]struct{})
+	simultaneous := true
+	for _, e := range gc.pre {
+		hint.ThreadIDs = append(hint.ThreadIDs, e.ThreadID)
+		queues[e.Queue] = struct{}{}
+		if gap.StartTime.Sub(e.Timestamp.Time) > gapStallSkew {
+			simultaneous = false
+		}
+	}
+	sort.Strings(hint.ThreadIDs)
+
+	burst := gc.post.Entries > 0 &&
+		gc.post.AvgMS >= gapQueueBurstMinMS &&
+		gc.post.AvgMS >= gapQueueBurstFactor*gc.post.BaselineAvgMS
+
+	switch {
+	case gc.beforeFile != gc.afterFile || gap.AfterLine < gap.BeforeLine:
+		hint.Classification = domain.GapLogRotation
+		hint.Reason = fmt.Sprintf("logging moved from file %d line %d to file %d line %d",
+			gc.beforeFile, gap.BeforeLine, gc.afterFile, gap.AfterLine)
+	case len(gc.pre) == 0:
+		hint.Reason = "no thread activity before the gap"
+	case len(gc.pre) == 1:
+		e := gc.pre[0]
+		hint.Classification = domain.GapSingleThreadBlock
+		hint.Reason = fmt.Sprintf("only thread %s was active before the gap; last call %s took %dms",
+			e.ThreadID, e.Identifier, e.DurationMS)
+	case len(queues) > 1 && simultaneous:
+		hint.Classification = domain.GapServerStall
+		hint.Reason = fmt.Sprintf("%d threads across %d queues went silent together", len(gc.pre), len(queues))
+	case len(queues) == 1 && burst:
+		hint.Classification = domain.GapQueueStarvation
+		hint.Reason = fmt.Sprintf("%d threads of queue %q went silent and queue time after the gap averaged %.0fms against %.0fms overall",
+			len(gc.pre), gc.pre[0].Queue, gc.post.AvgMS, gc.post.BaselineAvgMS)
+	default:
+		hint.Reason = "activity before and after the gap matches no rule"
+	}
+	return hint
+}
+
+// attachGapHints classifies every gap using two queries batched across all
+// gaps: the last entry of each thread before each gap, and the queue time
+// statistics after each gap. before/after files are the file numbers of the
+// entries bounding each gap, in the same order as gaps.
+func (c *ClickHouseClient) attachGapHints(ctx context.Context, tenantID, jobID string, gaps []domain.GapEntry, beforeFiles, afterFiles []int) error {
+	if len(gaps) == 0 {
+		return nil
+	}
+
+	starts := make([]int64, len(gaps))
+	ends := make([]int64, len(gaps))
+	for i, g := range gaps {
+		starts[i] = g.StartTime.UnixMilli()
+		ends[i] = g.EndTime.UnixMilli()
+	}
+	windowMS := gapContextWindow.Milliseconds()
+
+	contexts := make([]gapContext, len(gaps))
+	for i := range contexts {
+		contexts[i].beforeFile = beforeFiles[i]
+		contexts[i].afterFile = afterFiles[i]
+	}
+
+	preRows, err := c.conn.Query(ctx, `
+		SELECT
+			gap_idx,
+			thread_id,
+			argMax(queue, (timestamp, line_number)) AS last_queue,
+			max(timestamp) AS last_ts,
+			argMax(line_number, (timestamp, line_number)) AS last_line,
+			argMax(file_number, (timestamp, line_number)) AS last_file,
+			argMax(toString(log_type), (timestamp, line_number)) AS last_type,
+			argMax(multiIf(api_code != '', api_code, sql_table != '', sql_table, filter_name != '', filter_name, esc_name),
+				(timestamp, line_number)) AS last_identifier,
+			argMax(duration_ms, (timestamp, line_number)) AS last_duration
+		FROM (
+			SELECT arrayJoin(arrayEnumerate(@starts)) AS gap_idx, *
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID AND thread_id != ''
+		)
+		WHERE toUnixTimestamp64Milli(timestamp) <= arrayElement(@starts, gap_idx)
+			AND toUnixTimestamp64Milli(timestamp) > arrayElement(@starts, gap_idx) - @windowMS
+		GROUP BY gap_idx, thread_id
+		ORDER BY gap_idx, last_ts DESC, thread_id
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("starts", starts),
+		clickhouse.Named("windowMS", windowMS),
+	)
+	if err != nil {
+		return fmt.Errorf("clickhouse: gap pre-activity: %w", err)
+	}
+	defer preRows.Close()
+
+	for preRows.Next() {
+		var (
+			idx      uint32
+			e        domain.GapThreadEntry
+			line     uint32
+			file     uint16
+			logType  string
+			duration uint32
+		)
+		if err := preRows.Scan(&idx, &e.ThreadID, &e.Queue, &e.Timestamp, &line, &file, &logType, &e.Identifier, &duration); err != nil {
+			return fmt.Errorf("clickhouse: gap pre-activity scan: %w", err)
+		}
+		if idx < 1 || int(idx) > len(contexts) {
+			continue
+		}
+		e.LineNumber = int(line)
+		e.FileNumber = int(file)
+		e.LogType = domain.LogType(logType)
+		e.DurationMS = int64(duration)
+		contexts[idx-1].pre = append(contexts[idx-1].pre, e)
+	}
+	if err := preRows.Err(); err != nil {
+		return fmt.Errorf("clickhouse: gap pre-activity rows: %w", err)
+	}
+
+	postRows, err := c.conn.Query(ctx, `
+		SELECT
+			gap_idx,
+			count() AS entries,
+			avg(queue_time_ms) AS avg_ms,
+			toInt64(max(queue_time_ms)) AS max_ms,
+			(
+				SELECT avg(queue_time_ms) FROM log_entries
+				WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = 'API'
+			) AS baseline_ms
+		FROM (
+			SELECT arrayJoin(arrayEnumerate(@ends)) AS gap_idx, timestamp, queue_time_ms
+			FROM log_entries
+			WHERE tenant_id = @tenantID AND job_id = @jobID AND log_type = 'API'
+		)
+		WHERE toUnixTimestamp64Milli(timestamp) >= arrayElement(@ends, gap_idx)
+			AND toUnixTimestamp64Milli(timestamp) < arrayElement(@ends, gap_idx) + @windowMS
+		GROUP BY gap_idx
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("ends", ends),
+		clickhouse.Named("windowMS", windowMS),
+	)
+	if err != nil {
+		return fmt.Errorf("clickhouse: gap post-queue time: %w", err)
+	}
+	defer postRows.Close()
+
+	for postRows.Next() {
+		var (
+			idx   uint32
+			stats domain.GapQueueTimeStats
+			count uint64
+		)
+		if err := postRows.Scan(&idx, &count, &stats.AvgMS, &stats.MaxMS, &stats.BaselineAvgMS); err != nil {
+			return fmt.Errorf("clickhouse: gap post-queue time scan: %w", err)
+		}
+		if idx < 1 || int(idx) > len(contexts) {
+			continue
+		}
+		stats.Entries = int64(count)
+		contexts[idx-1].post = stats
+	}
+	if err := postRows.Err(); err != nil {
+		return fmt.Errorf("clickhouse: gap post-queue time rows: %w", err)
+	}
+
+	for i := range gaps {
+		hint := classifyGap(gaps[i], contexts[i])
+		gaps[i].Hint = &hint
+	}
+	return nil
+}
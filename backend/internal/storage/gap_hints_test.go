@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var gapStart = time.Date(2026, 2, 10, 9, 30, 0, 0, time.UTC)
+
+// gapFixture returns a 30s gap between lines 100 and 101 of file 1.
+func gapFixture() domain.GapEntry {
+	return domain.GapEntry{
+		StartTime:  domain.NewTimestamp(gapStart),
+		EndTime:    domain.NewTimestamp(gapStart.Add(30 * time.Second)),
+		DurationMS: 30000,
+		BeforeLine: 100,
+		AfterLine:  101,
+		LogType:    domain.LogTypeAPI,
+	}
+}
+
+func threadEntry(thread, queue string, before time.Duration) domain.GapThreadEntry {
+	return domain.GapThreadEntry{
+		ThreadID:   thread,
+		Queue:      queue,
+		Timestamp:  domain.NewTimestamp(gapStart.Add(-before)),
+		LineNumber: 100,
+		FileNumber: 1,
+		LogType:    domain.LogTypeAPI,
+		Identifier: "GLEWF",
+		DurationMS: 25,
+	}
+}
+
+func TestClassifyGap(t *testing.T) {
+	tests := []struct {
+		name   string
+		gap    func(g *domain.GapEntry)
+		ctx    gapContext
+		want   domain.GapClassification
+		reason string
+	}{
+		{
+			name: "new file after the gap",
+			ctx: gapContext{beforeFile: 1, afterFile: 2, pre: []domain.GapThreadEntry{
+				threadEntry("T1", "Fast", 0), threadEntry("T2", "List", 0),
+			}},
+			want:   domain.GapLogRotation,
+			reason: "logging moved from file 1 line 100 to file 2 line 101",
+		},
+		{
+			name: "line numbers restart",
+			gap:  func(g *domain.GapEntry) { g.AfterLine = 1 },
+			ctx:  gapContext{beforeFile: 1, afterFile: 1},
+			want: domain.GapLogRotation,
+		},
+		{
+			name: "all queues silent together",
+			ctx: gapContext{beforeFile: 1, afterFile: 1, pre: []domain.GapThreadEntry{
+				threadEntry("T1", "Fast", 0),
+				threadEntry("T2", "List", 200*time.Millisecond),
+				threadEntry("T3", "Fast", 900*time.Millisecond),
+			}},
+			want:   domain.GapServerStall,
+			reason: "3 threads across 2 queues went silent together",
+		},
+		{
+			name: "one queue silent with queued work after",
+			ctx: gapContext{
+				beforeFile: 1, afterFile: 1,
+				pre: []domain.GapThreadEntry{
+					threadEntry("T1", "Fast", 0),
+					threadEntry("T2", "Fast", 20*time.Second),
+				},
+				post: domain.GapQueueTimeStats{Entries: 40, AvgMS: 4200, MaxMS: 29000, BaselineAvgMS: 15},
+			},
+			want: domain.GapQueueStarvation,
+		},
+		{
+			name: "single busy thread",
+			ctx: gapContext{beforeFile: 1, afterFile: 1, pre: []domain.GapThreadEntry{
+				{ThreadID: "T9", Queue: "Admin", Timestamp: domain.NewTimestamp(gapStart), Identifier: "EXP", DurationMS: 29500},
+			}},
+			want:   domain.GapSingleThreadBlock,
+			reason: "only thread T9 was active before the gap; last call EXP took 29500ms",
+		},
+		{
+			name:   "no activity before the gap",
+			ctx:    gapContext{beforeFile: 1, afterFile: 1},
+			want:   domain.GapUnknown,
+			reason: "no thread activity before the gap",
+		},
+		{
+			name: "one queue without a queue-time burst",
+			ctx: gapContext{
+				beforeFile: 1, afterFile: 1,
+				pre: []domain.GapThreadEntry{
+					threadEntry("T1", "Fast", 0),
+					threadEntry("T2", "Fast", 0),
+				},
+				post: domain.GapQueueTimeStats{Entries: 40, AvgMS: 20, MaxMS: 60, BaselineAvgMS: 15},
+			},
+			want: domain.GapUnknown,
+		},
+		{
+			name: "burst below the absolute minimum",
+			ctx: gapContext{
+				beforeFile: 1, afterFile: 1,
+				pre: []domain.GapThreadEntry{
+					threadEntry("T1", "Fast", 0),
+					threadEntry("T2", "Fast", 0),
+				},
+				post: domain.GapQueueTimeStats{Entries: 40, AvgMS: 50, MaxMS: 90, BaselineAvgMS: 2},
+			},
+			want: domain.GapUnknown,
+		},
+		{
+			name: "queues went quiet at different times",
+			ctx: gapContext{beforeFile: 1, afterFile: 1, pre: []domain.GapThreadEntry{
+				threadEntry("T1", "Fast", 0),
+				threadEntry("T2", "List", 45*time.Second),
+			}},
+			want: domain.GapUnknown,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gap := gapFixture()
+			if tc.gap != nil {
+				tc.gap(&gap)
+			}
+			hint := classifyGap(gap, tc.ctx)
+			assert.Equal(t, tc.want, hint.Classification)
+			assert.NotEmpty(t, hint.Reason)
+			if tc.reason != "" {
+				assert.Equal(t, tc.reason, hint.Reason)
+			}
+			assert.Len(t, hint.ThreadIDs, len(tc.ctx.pre))
+			assert.NotNil(t, hint.PreGapEntries)
+			assert.Equal(t, tc.ctx.post, hint.PostGapQueueTime)
+		})
+	}
+}
+
+func TestClassifyGap_EvidenceIsBoundedAndSorted(t *testing.T) {
+	var pre []domain.GapThreadEntry
+	for _, id := range []string{"T15", "T03", "T11", "T07", "T01", "T09", "T13", "T05", "T02", "T04", "T06", "T08"} {
+		pre = append(pre, threadEntry(id, "Fast", 0))
+	}
+	hint := classifyGap(gapFixture(), gapContext{beforeFile: 1, afterFile: 1, pre: pre})
+
+	assert.Len(t, hint.PreGapEntries, maxGapEvidenceEntries)
+	assert.Len(t, hint.ThreadIDs, len(pre))
+	assert.IsIncreasing(t, hint.ThreadIDs)
+}
+
+func TestAttachGapHints(t *testing.T) {
+	gaps := []domain.GapEntry{gapFixture(), gapFixture()}
+	gaps[1].StartTime = domain.NewTimestamp(gapStart.Add(time.Hour))
+	gaps[1].EndTime = domain.NewTimestamp(gapStart.Add(time.Hour + 10*time.Second))
+
+	conn := &fakeConn{results: [][][]any{
+		// Pre-gap activity: two queues before gap 1, one thread before gap 2.
+		{
+			{uint32(1), "T1", "Fast", gapStart, uint32(98), uint16(1), "API", "SE", uint32(12)},
+			{uint32(1), "T2", "List", gapStart.Add(-300 * time.Millisecond), uint32(97), uint16(1), "SQL", "T1234", uint32(3)},
+			{uint32(2), "T7", "Admin", gaps[1].StartTime, uint32(400), uint16(1), "API", "EXP", uint32(9800)},
+			{uint32(9), "T8", "Admin", gaps[1].StartTime, uint32(401), uint16(1), "API", "EXP", uint32(1)},
+		},
+		// Post-gap queue times: only gap 2 had API calls afterwards.
+		{
+			{uint32(2), uint64(5), 12.0, int64(30), 10.0},
+		},
+	}}
+
+	err := (&ClickHouseClient{conn: conn}).attachGapHints(context.Background(), testTenantID, testJobID, gaps, []int{1, 1}, []int{1, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, conn.queries, "hints for all gaps come from two queries")
+
+	require.NotNil(t, gaps[0].Hint)
+	assert.Equal(t, domain.GapServerStall, gaps[0].Hint.Classification)
+	assert.Equal(t, []string{"T1", "T2"}, gaps[0].Hint.ThreadIDs)
+	assert.Equal(t, domain.LogTypeSQL, gaps[0].Hint.PreGapEntries[1].LogType)
+	assert.Zero(t, gaps[0].Hint.PostGapQueueTime.Entries)
+
+	require.NotNil(t, gaps[1].Hint)
+	assert.Equal(t, domain.GapSingleThreadBlock, gaps[1].Hint.Classification)
+	assert.Equal(t, int64(9800), gaps[1].Hint.PreGapEntries[0].DurationMS)
+	assert.Equal(t, domain.GapQueueTimeStats{Entries: 5, AvgMS: 12, MaxMS: 30, BaselineAvgMS: 10}, gaps[1].Hint.PostGapQueueTime)
+}
+
+func TestAttachGapHints_NoGaps(t *testing.T) {
+	conn := &fakeConn{}
+	require.NoError(t, (&ClickHouseClient{conn: conn}).attachGapHints(context.Background(), testTenantID, testJobID, nil, nil, nil))
+	assert.Zero(t, conn.queries)
+}
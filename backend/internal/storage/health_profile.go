@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// healthWeightTolerance is how far the factor weights of a profile may sum
+// from 1.0, to absorb decimal rounding in hand-edited profiles.
+const healthWeightTolerance = 1e-6
+
+// healthFactorOrder is the order factors are scored and reported in.
+var healthFactorOrder = []domain.HealthFactorName{
+	domain.HealthFactorErrorRate,
+	domain.HealthFactorResponseTime,
+	domain.HealthFactorThreadSaturation,
+	domain.HealthFactorGapFrequency,
+}
+
+// healthFactorLabels are the display names of the factors.
+var healthFactorLabels = map[domain.HealthFactorName]string{
+	domain.HealthFactorErrorRate:        "Error Rate",
+	domain.HealthFactorResponseTime:     "Avg Response Time",
+	domain.HealthFactorThreadSaturation: "Thread Saturation",
+	domain.HealthFactorGapFrequency:     "Gap Frequency",
+}
+
+// DefaultHealthProfile returns the built-in profile (version 0) used by
+// tenants that never customised their health score.
+func DefaultHealthProfile() *domain.HealthProfile {
+	steps := func(b1, b2, b3, b4 float64) []domain.HealthBreakpoint {
+		return []domain.HealthBreakpoint{
+			{Below: b1, Score: 100},
+			{Below: b2, Score: 80},
+			{Below: b3, Score: 50},
+			{Below: b4, Score: 25},
+		}
+	}
+	return &domain.HealthProfile{
+		Factors: []domain.HealthFactorConfig{
+			{Name: domain.HealthFactorErrorRate, Weight: 0.30, Breakpoints: steps(0.01, 0.02, 0.05, 0.10)},
+			{Name: domain.HealthFactorResponseTime, Weight: 0.25, Breakpoints: steps(500, 1000, 2000, 5000)},
+			{Name: domain.HealthFactorThreadSaturation, Weight: 0.25, Breakpoints: steps(50, 70, 85, 95)},
+			{Name: domain.HealthFactorGapFrequency, Weight: 0.20, Breakpoints: steps(5, 15, 30, 60)},
+		},
+		RedBelow:   50,
+		GreenAbove: 80,
+	}
+}
+
+// LoadHealthProfile returns the latest health profile of a tenant, or the
+// default profile when the tenant has not stored one.
+func LoadHealthProfile(ctx context.Context, pg PostgresStore, tenantID uuid.UUID) (*domain.HealthProfile, error) {
+	p, err := pg.GetHealthProfile(ctx, tenantID)
+	if err != nil {
+		if IsNotFound(err) {
+			p = DefaultHealthProfile()
+			p.TenantID = tenantID
+			return p, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// ValidateHealthProfile checks that a profile configures every factor once,
+// that the weights are within [0, 1] and sum to 1.0, that at least one
+// enabled factor carries weight, that breakpoints ascend and that all
+// scores and status thresholds are within 0-100.
+func ValidateHealthProfile(p *domain.HealthProfile) error {
+	seen := make(map[domain.HealthFactorName]bool, len(p.Factors))
+	var total, enabled float64
+	for _, f := range p.Factors {
+		if _, ok := healthFactorLabels[f.Name]; !ok {
+			return fmt.Errorf("unknown factor %q", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("factor %q is configured twice", f.Name)
+		}
+		seen[f.Name] = true
+
+		if f.Weight < 0 || f.Weight > 1 || math.IsNaN(f.Weight) {
+			return fmt.Errorf("factor %q: weight must be between 0 and 1", f.Name)
+		}
+		total += f.Weight
+		if !f.Disabled {
+			enabled += f.Weight
+		}
+
+		if len(f.Breakpoints) == 0 {
+			return fmt.Errorf("factor %q: at least one breakpoint is required", f.Name)
+		}
+		for i, b := range f.Breakpoints {
+			if math.IsNaN(b.Below) || math.IsInf(b.Below, 0) {
+				return fmt.Errorf("factor %q: breakpoint %d is not a number", f.Name, i)
+			}
+			if i > 0 && b.Below <= f.Breakpoints[i-1].Below {
+				return fmt.Errorf("factor %q: breakpoints must be in ascending order", f.Name)
+			}
+			if b.Score < 0 || b.Score > 100 {
+				return fmt.Errorf("factor %q: breakpoint scores must be between 0 and 100", f.Name)
+			}
+		}
+		if f.OtherwiseScore < 0 || f.OtherwiseScore > 100 {
+			return fmt.Errorf("factor %q: otherwise_score must be between 0 and 100", f.Name)
+		}
+	}
+	for _, name := range healthFactorOrder {
+		if !seen[name] {
+			return fmt.Errorf("factor %q is missing", name)
+		}
+	}
+	if math.Abs(total-1) > healthWeightTolerance {
+		return fmt.Errorf("factor weights must sum to 1.0, got %g", total)
+	}
+	if enabled <= 0 {
+		return fmt.Errorf("at least one enabled factor must have a weight")
+	}
+	if p.RedBelow < 0 || p.GreenAbove > 100 || p.RedBelow > p.GreenAbove {
+		return fmt.Errorf("status thresholds must satisfy 0 <= red_below <= green_above <= 100")
+	}
+	return nil
+}
+
+// healthMetrics are the job metrics the health factors score.
+type healthMetrics struct {
+	errorRate     float64 // fraction of failed operations
+	avgDurationMS float64 // of the calls timed above zero
+	maxBusyPct    float64
+	maxGapSeconds float64
+
+	// restartGaps and warmupWindows count the gaps and the post-restart
+	// windows left out of the metrics above.
+	restartGaps   int
+	warmupWindows int
+}
+
+func (m healthMetrics) value(name domain.HealthFactorName) float64 {
+	switch name {
+	case domain.HealthFactorErrorRate:
+		return m.errorRate
+	case domain.HealthFactorResponseTime:
+		return m.avgDurationMS
+	case domain.HealthFactorThreadSaturation:
+		return m.maxBusyPct
+	default:
+		return m.maxGapSeconds
+	}
+}
+
+func (m healthMetrics) describe(name domain.HealthFactorName) string {
+	switch name {
+	case domain.HealthFactorErrorRate:
+		return fmt.Sprintf("%.2f%% of operations failed", m.errorRate*100)
+	case domain.HealthFactorResponseTime:
+		desc := fmt.Sprintf("%.0fms average duration of timed calls", m.avgDurationMS)
+		if m.warmupWindows > 0 {
+			desc += fmt.Sprintf(", excluding %d restart warm-up window(s)", m.warmupWindows)
+		}
+		return desc
+	case domain.HealthFactorThreadSaturation:
+		return fmt.Sprintf("%.0f%% max thread utilization", m.maxBusyPct)
+	default:
+		desc := fmt.Sprintf("%.1fs longest gap", m.maxGapSeconds)
+		if m.restartGaps > 0 {
+			desc += fmt.Sprintf(", excluding %d server restart(s)", m.restartGaps)
+		}
+		return desc
+	}
+}
+
+// scoreHealth computes the health score of metrics under profile, which
+// must be valid. Disabled factors are left out of the factor list and their
+// weight is spread over the enabled factors, so reported weights always sum
+// to 1.0.
+func scoreHealth(m healthMetrics, profile *domain.HealthProfile) *domain.HealthScore {
+	// Weights are only rescaled when a factor is disabled, so that a
+	// profile without disabled factors scores with exactly its weights.
+	enabled, renormalize := 0.0, false
+	for _, f := range profile.Factors {
+		if f.Disabled {
+			renormalize = true
+		} else {
+			enabled += f.Weight
+		}
+	}
+
+	byName := make(map[domain.HealthFactorName]domain.HealthFactorConfig, len(profile.Factors))
+	for _, f := range profile.Factors {
+		byName[f.Name] = f
+	}
+
+	factors := make([]domain.HealthScoreFactor, 0, len(healthFactorOrder))
+	var composite float64
+	for _, name := range healthFactorOrder {
+		f, ok := byName[name]
+		if !ok || f.Disabled || enabled <= 0 {
+			continue
+		}
+		weight := f.Weight
+		if renormalize {
+			weight /= enabled
+		}
+		score := scoreFactor(f, m.value(name))
+		composite += float64(score) * weight
+		factors = append(factors, domain.HealthScoreFactor{
+			Name:        healthFactorLabels[name],
+			Score:       score,
+			MaxScore:    100,
+			Weight:      weight,
+			Description: m.describe(name),
+			Severity:    healthStatus(score, profile),
+		})
+	}
+
+	score := int(math.Round(composite))
+	return &domain.HealthScore{
+		Score:          score,
+		Status:         healthStatus(score, profile),
+		Factors:        factors,
+		ProfileVersion: profile.Version,
+	}
+}
+
+// scoreFactor scores a metric against the breakpoints of a factor.
+func scoreFactor(f domain.HealthFactorConfig, value float64) int {
+	for _, b := range f.Breakpoints {
+		if value < b.Below {
+			return b.Score
+		}
+	}
+	return f.OtherwiseScore
+}
+
+// healthStatus maps a score to red, yellow or green.
+func healthStatus(score int, profile *domain.HealthProfile) string {
+	switch {
+	case score < profile.RedBelow:
+		return "red"
+	case score > profile.GreenAbove:
+		return "green"
+	default:
+		return "yellow"
+	}
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestValidateHealthProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(p *domain.HealthProfile)
+		wantErr string
+	}{
+		{name: "default profile is valid"},
+		{
+			name:   "rebalanced weights",
+			mutate: func(p *domain.HealthProfile) { p.Factors[0].Weight, p.Factors[3].Weight = 0.45, 0.05 },
+		},
+		{
+			name:   "decimal rounding tolerated",
+			mutate: func(p *domain.HealthProfile) { p.Factors[0].Weight, p.Factors[1].Weight = 0.1+0.2, 0.25 },
+		},
+		{
+			name:    "weights below one",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[0].Weight = 0.20 },
+			wantErr: "must sum to 1.0",
+		},
+		{
+			name:    "weights above one",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[0].Weight = 0.40 },
+			wantErr: "must sum to 1.0",
+		},
+		{
+			name:    "negative weight",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[0].Weight, p.Factors[1].Weight = -0.10, 0.65 },
+			wantErr: "weight must be between 0 and 1",
+		},
+		{
+			name:    "NaN weight",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[0].Weight = math.NaN() },
+			wantErr: "weight must be between 0 and 1",
+		},
+		{
+			name: "only zero-weight factors enabled",
+			mutate: func(p *domain.HealthProfile) {
+				p.Factors[0].Weight, p.Factors[1].Weight, p.Factors[2].Weight, p.Factors[3].Weight = 1, 0, 0, 0
+				p.Factors[0].Disabled = true
+			},
+			wantErr: "at least one enabled factor",
+		},
+		{
+			name:    "missing factor",
+			mutate:  func(p *domain.HealthProfile) { p.Factors = p.Factors[:3] },
+			wantErr: `factor "gap_frequency" is missing`,
+		},
+		{
+			name:    "duplicate factor",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[3].Name = domain.HealthFactorErrorRate },
+			wantErr: "configured twice",
+		},
+		{
+			name:    "unknown factor",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[3].Name = "cpu" },
+			wantErr: "unknown factor",
+		},
+		{
+			name:    "descending breakpoints",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[1].Breakpoints[1].Below = 100 },
+			wantErr: "ascending order",
+		},
+		{
+			name:    "no breakpoints",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[1].Breakpoints = nil },
+			wantErr: "at least one breakpoint",
+		},
+		{
+			name:    "breakpoint score out of range",
+			mutate:  func(p *domain.HealthProfile) { p.Factors[1].Breakpoints[0].Score = 101 },
+			wantErr: "between 0 and 100",
+		},
+		{
+			name:    "red above green",
+			mutate:  func(p *domain.HealthProfile) { p.RedBelow, p.GreenAbove = 90, 80 },
+			wantErr: "status thresholds",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := DefaultHealthProfile()
+			if tc.mutate != nil {
+				tc.mutate(p)
+			}
+			err := ValidateHealthProfile(p)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+// legacyHealthScore is the composite ComputeHealthScore used before health
+// profiles, kept to pin the default profile to it.
+func legacyHealthScore(m healthMetrics) (int, string) {
+	composite := float64(scoreErrorRate(m.errorRate))*0.30 +
+		float64(scoreResponseTime(m.avgDurationMS))*0.25 +
+		float64(scoreThreadSaturation(m.maxBusyPct))*0.25 +
+		float64(scoreGapFrequency(m.maxGapSeconds))*0.20
+	score := int(math.Round(composite))
+	status := "green"
+	if score < 50 {
+		status = "red"
+	} else if score <= 80 {
+		status = "yellow"
+	}
+	return score, status
+}
+
+func TestScoreHealth_DefaultProfileMatchesLegacy(t *testing.T) {
+	samples := []healthMetrics{
+		{},
+		{errorRate: 0.005, avgDurationMS: 300, maxBusyPct: 40, maxGapSeconds: 2},
+		{errorRate: 0.015, avgDurationMS: 750, maxBusyPct: 60, maxGapSeconds: 10},
+		{errorRate: 0.03, avgDurationMS: 1500, maxBusyPct: 80, maxGapSeconds: 20},
+		{errorRate: 0.07, avgDurationMS: 3000, maxBusyPct: 90, maxGapSeconds: 45},
+		{errorRate: 0.5, avgDurationMS: 9000, maxBusyPct: 100, maxGapSeconds: 600},
+		{errorRate: 0.01, avgDurationMS: 500, maxBusyPct: 50, maxGapSeconds: 5},
+		{errorRate: 0.02, avgDurationMS: 2000, maxBusyPct: 95, maxGapSeconds: 60},
+	}
+	for _, m := range samples {
+		wantScore, wantStatus := legacyHealthScore(m)
+		got := scoreHealth(m, DefaultHealthProfile())
+		assert.Equal(t, wantScore, got.Score, "metrics %+v", m)
+		assert.Equal(t, wantStatus, got.Status, "metrics %+v", m)
+		assert.Equal(t, 0, got.ProfileVersion)
+		require.Len(t, got.Factors, 4)
+		assert.Equal(t, []float64{0.30, 0.25, 0.25, 0.20},
+			[]float64{got.Factors[0].Weight, got.Factors[1].Weight, got.Factors[2].Weight, got.Factors[3].Weight})
+		assert.Equal(t, "Error Rate", got.Factors[0].Name)
+		assert.Equal(t, "Gap Frequency", got.Factors[3].Name)
+	}
+}
+
+func TestScoreHealth_DisabledFactorRenormalizes(t *testing.T) {
+	p := DefaultHealthProfile()
+	p.Version = 4
+	// Disable thread saturation (0.25); the remaining 0.75 is rescaled.
+	p.Factors[2].Disabled = true
+	require.NoError(t, ValidateHealthProfile(p))
+
+	// Error rate 100, response time 50, gap 0; saturation would be 0.
+	m := healthMetrics{errorRate: 0, avgDurationMS: 1500, maxBusyPct: 99, maxGapSeconds: 120}
+	got := scoreHealth(m, p)
+
+	require.Len(t, got.Factors, 3)
+	var total float64
+	for _, f := range got.Factors {
+		assert.NotEqual(t, "Thread Saturation", f.Name)
+		total += f.Weight
+	}
+	assert.InDelta(t, 1.0, total, 1e-9)
+	assert.InDelta(t, 0.40, got.Factors[0].Weight, 1e-9)
+	assert.InDelta(t, 0.25/0.75, got.Factors[1].Weight, 1e-9)
+	// 100*0.4 + 50*(1/3) + 0*(0.2/0.75) = 56.67
+	assert.Equal(t, 57, got.Score)
+	assert.Equal(t, "yellow", got.Status)
+	assert.Equal(t, 4, got.ProfileVersion)
+}
+
+func TestScoreHealth_CustomThresholds(t *testing.T) {
+	p := DefaultHealthProfile()
+	p.RedBelow, p.GreenAbove = 90, 95
+	got := scoreHealth(healthMetrics{errorRate: 0.015}, p)
+	// 80*0.30 + 100*0.70 = 94
+	assert.Equal(t, 94, got.Score)
+	assert.Equal(t, "yellow", got.Status)
+	assert.Equal(t, "red", got.Factors[0].Severity)
+}
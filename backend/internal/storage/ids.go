@@ -0,0 +1,23 @@
+package storage
+
+// isCanonicalID reports whether s is a UUID in the canonical form the API
+// passes identifiers in: 36 characters, hyphenated, lowercase.
+func isCanonicalID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+//go:build debug
+
+package storage
+
+import "fmt"
+
+// assertCanonicalIDs panics when an identifier handed to the storage layer
+// is not in canonical form, which string comparisons in ClickHouse, Redis
+// keys and the cluster routes rely on. Only debug builds check.
+func assertCanonicalIDs(ids ...string) {
+	for _, id := range ids {
+		if id != "" && !isCanonicalID(id) {
+			panic(fmt.Sprintf("storage: identifier %q is not a canonical UUID", id))
+		}
+	}
+}
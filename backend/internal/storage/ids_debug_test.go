@@ -0,0 +1,14 @@
+//go:build debug
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertCanonicalIDs(t *testing.T) {
+	assert.NotPanics(t, func() { assertCanonicalIDs("0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d", "") })
+	assert.Panics(t, func() { assertCanonicalIDs("0B7D5A4E-3F0C-4C1E-9A8E-5D6F7A8B9C0D") })
+}
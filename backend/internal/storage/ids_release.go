@@ -0,0 +1,6 @@
+//go:build !debug
+
+package storage
+
+// assertCanonicalIDs checks identifiers in debug builds only.
+func assertCanonicalIDs(...string) {}
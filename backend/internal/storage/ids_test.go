@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testTenantID and testJobID are the identifiers of the tests of methods
+// whose identifiers debug builds check.
+const (
+	testTenantID = "0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d"
+	testJobID    = "6f1c2d3e-4a5b-4c6d-8e7f-901a2b3c4d5e"
+)
+
+func TestIsCanonicalID(t *testing.T) {
+	assert.True(t, isCanonicalID("0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d"))
+	for _, id := range []string{
+		"0B7D5A4E-3F0C-4C1E-9A8E-5D6F7A8B9C0D",
+		"{0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d}",
+		"urn:uuid:0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0d",
+		"0b7d5a4e3f0c4c1e9a8e5d6f7a8b9c0d",
+		"0b7d5a4e-3f0c-4c1e-9a8e-5d6f7a8b9c0g",
+		"tenant-1",
+		"",
+	} {
+		assert.False(t, isCanonicalID(id), id)
+	}
+}
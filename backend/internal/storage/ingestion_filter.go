@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// CountIngestionFilterMatches returns how many stored entries of a job an
+// ingestion filter rule matches, noise included. Entries a rule dropped when
+// the job was ingested are gone and cannot be counted.
+func (c *ClickHouseClient) CountIngestionFilterMatches(ctx context.Context, tenantID, jobID string, rule domain.IngestionFilterRule) (int64, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	cond, err := ingestionFilterCondition(rule)
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+	err = c.conn.QueryRow(ctx, `
+		SELECT count()
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND `+cond,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("value", rule.Value),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("clickhouse: count ingestion filter matches: %w", err)
+	}
+	return int64(count), nil
+}
+
+// ingestionFilterCondition is the WHERE condition matching the entries of
+// rule, comparing its field with the @value parameter the way the worker
+// does at ingestion.
+func ingestionFilterCondition(rule domain.IngestionFilterRule) (string, error) {
+	if !IsKnownField(rule.Field) {
+		return "", fmt.Errorf("clickhouse: unknown field for ingestion filter: %s", rule.Field)
+	}
+
+	col, value := "toString("+rule.Field+")", "@value"
+	if !rule.CaseSensitive {
+		col, value = "lowerUTF8("+col+")", "lowerUTF8("+value+")"
+	}
+
+	switch rule.Operator {
+	case domain.IngestionFilterEquals:
+		return col + " = " + value, nil
+	case domain.IngestionFilterPrefix:
+		return "startsWith(" + col + ", " + value + ")", nil
+	case domain.IngestionFilterSuffix:
+		return "endsWith(" + col + ", " + value + ")", nil
+	case domain.IngestionFilterContains:
+		return "position(" + col + ", " + value + ") > 0", nil
+	default:
+		return "", fmt.Errorf("clickhouse: unknown ingestion filter operator: %s", rule.Operator)
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestIngestionFilterCondition(t *testing.T) {
+	tests := []struct {
+		op            domain.IngestionFilterOperator
+		caseSensitive bool
+		want          string
+	}{
+		{domain.IngestionFilterEquals, true, "toString(user) = @value"},
+		{domain.IngestionFilterEquals, false, "lowerUTF8(toString(user)) = lowerUTF8(@value)"},
+		{domain.IngestionFilterPrefix, true, "startsWith(toString(user), @value)"},
+		{domain.IngestionFilterSuffix, false, "endsWith(lowerUTF8(toString(user)), lowerUTF8(@value))"},
+		{domain.IngestionFilterContains, true, "position(toString(user), @value) > 0"},
+	}
+	for _, tt := range tests {
+		got, err := ingestionFilterCondition(domain.IngestionFilterRule{Field: "user", Operator: tt.op, Value: "x", CaseSensitive: tt.caseSensitive})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := ingestionFilterCondition(domain.IngestionFilterRule{Field: "user; DROP TABLE log_entries", Operator: domain.IngestionFilterEquals})
+	assert.ErrorContains(t, err, "unknown field")
+	_, err = ingestionFilterCondition(domain.IngestionFilterRule{Field: "user", Operator: "regex"})
+	assert.ErrorContains(t, err, "unknown ingestion filter operator")
+}
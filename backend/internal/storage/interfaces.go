@@ -16,43 +16,202 @@ type PostgresStore interface {
 	CreateTenant(ctx context.Context, t *domain.Tenant) error
 	GetTenant(ctx context.Context, id uuid.UUID) (*domain.Tenant, error)
 	GetTenantByClerkOrg(ctx context.Context, clerkOrgID string) (*domain.Tenant, error)
+	ListTenants(ctx context.Context) ([]domain.Tenant, error)
+	UpdateTenantRetentionClass(ctx context.Context, id uuid.UUID, class domain.RetentionClass) error
+	SetTenantRegion(ctx context.Context, id uuid.UUID, region string) error
+	SetTenantSQLRedaction(ctx context.Context, id uuid.UUID, policy domain.SQLRedaction) error
+	TenantHasData(ctx context.Context, id uuid.UUID) (bool, error)
+	ListExpiredSandboxTenants(ctx context.Context, now time.Time) ([]domain.Tenant, error)
+	ConvertSandboxTenant(ctx context.Context, id uuid.UUID, now time.Time) (*domain.Tenant, error)
+	ArchiveTenant(ctx context.Context, id uuid.UUID, now time.Time) ([]string, error)
 	CreateLogFile(ctx context.Context, f *domain.LogFile) error
 	GetLogFile(ctx context.Context, tenantID uuid.UUID, fileID uuid.UUID) (*domain.LogFile, error)
 	ListLogFiles(ctx context.Context, tenantID uuid.UUID) ([]domain.LogFile, error)
+	DeleteLogFile(ctx context.Context, tenantID, fileID uuid.UUID) error
 	CreateJob(ctx context.Context, job *domain.AnalysisJob) error
 	GetJob(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) (*domain.AnalysisJob, error)
 	UpdateJobStatus(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, status domain.JobStatus, errMsg *string) error
+	RequestJobCancel(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) (*domain.AnalysisJob, bool, error)
 	UpdateJobProgress(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, progressPct int, processedLines *int64) error
+	UpdateJobResources(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, usage domain.JobResourceUsage) error
+	UpdateJobLogFormat(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, format domain.LogFormat) error
+	UpdateJobClockSkew(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, report *domain.ClockSkewReport) error
+	UpdateJobRestarts(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, restarts []domain.RestartEvent) error
+	UpdateJobThreadCounts(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, counts map[string]int) error
+	UpdateJobIngestionFilterStats(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, stats []domain.IngestionFilterMatch) error
+	UpdateJobSampling(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, sampling *domain.Sampling) error
+	UpdateJobSectionPresence(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, sections *domain.SectionPresence) error
+	UpdateJobParseDiagnostics(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, diag *domain.ParseDiagnostics) error
+	UpdateJobReconciliation(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, rec *domain.IngestionReconciliation) error
+	UpdateJobFileIntegrity(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, report *domain.FileIntegrity) error
+	UpdateJobErrorOnset(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, onset *domain.ErrorOnset) error
+	UpdateJobFocusWindow(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, window *domain.FocusWindow) error
+	UpdateJobCaptureDrift(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, profile *domain.CaptureProfile, drift *domain.CaptureDrift) error
+	GetCaptureReference(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) (*domain.AnalysisJob, string, error)
+	SetCaptureBaseline(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, baseline bool) error
+	GetJobErrorOnset(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) (*domain.ErrorOnset, error)
+	UpdateJobAPILegend(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, legend []domain.JARAPIAbbreviation) error
+	GetJobAPILegend(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) ([]domain.JARAPIAbbreviation, error)
 	ListJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error)
+	CountJobs(ctx context.Context, tenantID uuid.UUID) (int, error)
+	ListJobsFiltered(ctx context.Context, tenantID uuid.UUID, filter domain.JobListFilter) ([]domain.AnalysisJob, error)
+	SoftDeleteJob(ctx context.Context, tenantID, jobID uuid.UUID) error
+	RestoreJob(ctx context.Context, tenantID, jobID uuid.UUID) error
+	ListDeletedJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error)
+	ListPurgeableJobs(ctx context.Context, before time.Time, limit int) ([]domain.AnalysisJob, error)
+	GetJobQueueSnapshot(ctx context.Context) (*domain.JobQueueSnapshot, error)
+	GetJobCostModel(ctx context.Context, tenantID uuid.UUID, sourceType domain.LogSourceType) (*domain.JobCostModel, error)
+	UpsertJobCostModel(ctx context.Context, m *domain.JobCostModel) error
+	ListJobCostSamples(ctx context.Context, perModel int) ([]domain.JobCostSample, error)
+	UpdateJobEstimateAccuracy(ctx context.Context, tenantID, jobID uuid.UUID, acc *domain.EstimateAccuracy) error
+	GetQueueActivity(ctx context.Context, finishedSince, workersSince time.Time) (*domain.QueueActivity, error)
+	RecordWorkerHeartbeat(ctx context.Context, hb *domain.WorkerHeartbeat) error
+	DeleteWorkerHeartbeat(ctx context.Context, workerID string) error
+	PurgeJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.PurgedJob, error)
+	UpdateJobInvestigation(ctx context.Context, tenantID, jobID uuid.UUID, expectedVersion int, update domain.InvestigationUpdate, actorID string) (*domain.Investigation, *domain.InvestigationEvent, error)
+	ListInvestigationEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.InvestigationEvent, error)
+	CreateSupportGrant(ctx context.Context, g *domain.SupportGrant) error
+	ListSupportGrants(ctx context.Context, tenantID uuid.UUID) ([]domain.SupportGrant, error)
+	GetActiveSupportGrant(ctx context.Context, tenantID uuid.UUID) (*domain.SupportGrant, error)
+	RevokeSupportGrant(ctx context.Context, tenantID, grantID uuid.UUID, revokedBy string) error
+	RecordSupportAccess(ctx context.Context, ev *domain.SupportAccessEvent) error
+	ListSupportAccessEvents(ctx context.Context, tenantID uuid.UUID, limit int) ([]domain.SupportAccessEvent, error)
 	CreateAIInteraction(ctx context.Context, ai *domain.AIInteraction) error
 	UpdateAIInteraction(ctx context.Context, tenantID uuid.UUID, aiID uuid.UUID, outputText *string, tokensUsed *int, latencyMS *int, status string) error
 	CreateSavedSearch(ctx context.Context, search *domain.SavedSearch) error
 	ListSavedSearches(ctx context.Context, tenantID uuid.UUID, userID string) ([]domain.SavedSearch, error)
+	ListTenantSavedSearches(ctx context.Context, tenantID uuid.UUID) ([]domain.SavedSearch, error)
 	DeleteSavedSearch(ctx context.Context, tenantID uuid.UUID, userID string, searchID uuid.UUID) error
 	RecordSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, jobID *uuid.UUID, kqlQuery string, resultCount int) error
 	GetSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, limit int) ([]domain.SearchHistoryEntry, error)
+	CreateSearchExport(ctx context.Context, e *domain.SearchExport) error
+	GetSearchExport(ctx context.Context, tenantID uuid.UUID, exportID uuid.UUID) (*domain.SearchExport, error)
+	ListSearchExports(ctx context.Context, tenantID uuid.UUID, limit int) ([]domain.SearchExport, error)
+	ListExpiredSearchExports(ctx context.Context, before time.Time, limit int) ([]domain.SearchExport, error)
+	UpdateSearchExportStatus(ctx context.Context, tenantID uuid.UUID, exportID uuid.UUID, status domain.ExportStatus, errMsg *string) error
+	UpdateSearchExportProgress(ctx context.Context, tenantID uuid.UUID, exportID uuid.UUID, progressPct int, rowCount int64) error
+	CompleteSearchExport(ctx context.Context, tenantID uuid.UUID, exportID uuid.UUID, s3Key string, rowCount, sizeBytes int64, prov *domain.ArtifactProvenance) error
+	CreateThresholdRule(ctx context.Context, rule *domain.ThresholdRule) error
+	GetThresholdRule(ctx context.Context, tenantID uuid.UUID, ruleID uuid.UUID) (*domain.ThresholdRule, error)
+	ListThresholdRules(ctx context.Context, tenantID uuid.UUID) ([]domain.ThresholdRule, error)
+	UpdateThresholdRule(ctx context.Context, rule *domain.ThresholdRule) error
+	DeleteThresholdRule(ctx context.Context, tenantID uuid.UUID, ruleID uuid.UUID) error
+	ReplaceJobViolations(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, violations []domain.ThresholdViolation) error
+	ListJobViolations(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) ([]domain.ThresholdViolation, error)
+	CreateIngestionFilterRule(ctx context.Context, rule *domain.IngestionFilterRule) error
+	GetIngestionFilterRule(ctx context.Context, tenantID uuid.UUID, ruleID uuid.UUID) (*domain.IngestionFilterRule, error)
+	ListIngestionFilterRules(ctx context.Context, tenantID uuid.UUID) ([]domain.IngestionFilterRule, error)
+	UpdateIngestionFilterRule(ctx context.Context, rule *domain.IngestionFilterRule) error
+	DeleteIngestionFilterRule(ctx context.Context, tenantID uuid.UUID, ruleID uuid.UUID) error
+	CreateAnalysisLink(ctx context.Context, link *domain.AnalysisLink) error
+	ListAnalysisLinks(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) ([]domain.AnalysisLink, error)
+	ListAnalysisLinksByJobs(ctx context.Context, tenantID uuid.UUID, jobIDs []uuid.UUID) (map[uuid.UUID][]domain.AnalysisLink, error)
+	ListAnalysisLinksToSync(ctx context.Context, staleBefore time.Time, limit int) ([]domain.AnalysisLink, error)
+	UpdateAnalysisLinkStatus(ctx context.Context, tenantID uuid.UUID, linkID uuid.UUID, status, summary string, syncedAt time.Time) error
+	DeleteAnalysisLink(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, linkID uuid.UUID) error
+	CreateIncidentGroup(ctx context.Context, g *domain.IncidentGroup, jobIDs []uuid.UUID) error
+	GetIncidentGroup(ctx context.Context, tenantID uuid.UUID, groupID uuid.UUID) (*domain.IncidentGroup, error)
+	SetJobIncidentGroup(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, groupID *uuid.UUID) error
+	GetTicketingIntegration(ctx context.Context, tenantID uuid.UUID, t domain.AnalysisLinkType) (*domain.TicketingIntegration, error)
+	UpsertTicketingIntegration(ctx context.Context, ti *domain.TicketingIntegration) error
+	DeleteTicketingIntegration(ctx context.Context, tenantID uuid.UUID, t domain.AnalysisLinkType) error
+	CreateLocalUser(ctx context.Context, u *domain.LocalUser) error
+	GetLocalUserByEmail(ctx context.Context, email string) (*domain.LocalUser, error)
+	CountLocalUsers(ctx context.Context) (int, error)
+	RecordLocalLogin(ctx context.Context, userID uuid.UUID, at time.Time) error
+	ListClickHouseRoutes(ctx context.Context) (map[uuid.UUID]string, error)
+	SetClickHouseRoute(ctx context.Context, tenantID uuid.UUID, cluster string) error
+	CreateTenantMigration(ctx context.Context, m *domain.TenantMigration) error
+	GetUnfinishedTenantMigration(ctx context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error)
+	GetLastCompletedTenantMigration(ctx context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error)
+	UpdateTenantMigrationStatus(ctx context.Context, id uuid.UUID, status domain.TenantMigrationStatus, errMsg string) error
+	RecordTenantMigrationChunk(ctx context.Context, c *domain.TenantMigrationChunk) error
+	ListTenantMigrationChunks(ctx context.Context, migrationID uuid.UUID) ([]domain.TenantMigrationChunk, error)
+	GetDigestSubscription(ctx context.Context, tenantID uuid.UUID) (*domain.DigestSubscription, error)
+	UpsertDigestSubscription(ctx context.Context, s *domain.DigestSubscription) error
+	ListDigestSubscriptions(ctx context.Context) ([]domain.DigestSubscription, error)
+	MarkDigestSent(ctx context.Context, tenantID uuid.UUID, periodEnd time.Time) error
+	GetHealthProfile(ctx context.Context, tenantID uuid.UUID) (*domain.HealthProfile, error)
+	GetHealthProfileVersion(ctx context.Context, tenantID uuid.UUID, version int) (*domain.HealthProfile, error)
+	CreateHealthProfile(ctx context.Context, hp *domain.HealthProfile, expectedVersion int) error
+	BeginIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, bool, error)
+	CompleteIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error
+	ReleaseIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+	RegisterBackgroundTask(ctx context.Context, task *domain.BackgroundTask) error
+	DueBackgroundTasks(ctx context.Context, now time.Time) ([]string, error)
+	ClaimBackgroundTask(ctx context.Context, name, workerID string, now, lockedUntil, nextRunAt time.Time) (string, bool, error)
+	FinishBackgroundTaskRun(ctx context.Context, run *domain.BackgroundTaskRun) error
+	ListBackgroundTasks(ctx context.Context) ([]domain.BackgroundTask, error)
+	RequestBackgroundTaskRun(ctx context.Context, name, requestedBy string) (*domain.BackgroundTask, error)
+	GetServerSettings(ctx context.Context) (*domain.ServerSettings, error)
+	CreateServerSettings(ctx context.Context, s *domain.ServerSettings, expectedVersion int) error
+	GetPreferences(ctx context.Context, tenantID uuid.UUID, userID, namespace string) (*domain.Preferences, error)
+	PutPreferences(ctx context.Context, prefs *domain.Preferences, expectedRevision int) error
+	CreateWorkspace(ctx context.Context, ws *domain.Workspace) error
+	GetWorkspace(ctx context.Context, tenantID, jobID, workspaceID uuid.UUID) (*domain.Workspace, error)
+	ListWorkspaces(ctx context.Context, tenantID, jobID uuid.UUID, userID string) ([]domain.Workspace, error)
+	UpdateWorkspace(ctx context.Context, ws *domain.Workspace, expectedVersion int) error
+	DeleteWorkspace(ctx context.Context, tenantID, jobID, workspaceID uuid.UUID) error
+	AppendJobEvents(ctx context.Context, events []domain.JobEvent) (int, error)
+	ListJobEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JobEvent, error)
+	GetJobCheckpoint(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.JobCheckpoint, error)
+	SaveJobCheckpoint(ctx context.Context, cp *domain.JobCheckpoint) error
+	DeleteJobCheckpoint(ctx context.Context, tenantID, jobID uuid.UUID) error
+	RecordUsageEvents(ctx context.Context, events []domain.UsageEvent) (int, error)
+	ListUsageEvents(ctx context.Context, since time.Time) ([]domain.UsageEvent, error)
+	ListUsageSources(ctx context.Context, since time.Time) ([]domain.UsageEvent, error)
+	GetTenantUsage(ctx context.Context, tenantID *uuid.UUID, from, to time.Time, includeSandbox bool) ([]domain.UsageMonth, error)
+	UpsertVocabulary(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, field string, values []domain.AutocompleteValue) (int, error)
+	EvictVocabulary(ctx context.Context, tenantID uuid.UUID, unseenSince time.Time, maxPerField int) (int64, error)
+	SearchVocabulary(ctx context.Context, tenantID uuid.UUID, field, query string, prefixOnly bool, limit int) ([]domain.VocabularyTerm, error)
 }
 
 type ClickHouseStore interface {
 	Ping(ctx context.Context) error
 	BatchInsertEntries(ctx context.Context, entries []domain.LogEntry) error
+	ResetSampleWeights(ctx context.Context, tenantID, jobID string, windows []domain.HotWindow) error
+	BuildJobRollup(ctx context.Context, tenantID, jobID string) error
 	GetLogEntry(ctx context.Context, tenantID, jobID, entryID string) (*domain.LogEntry, error)
+	ResolveEntryID(ctx context.Context, tenantID, jobID string, fileNumber uint16, lineNumber uint32) (string, error)
+	GetEntrySummaries(ctx context.Context, tenantID, jobID string, entryIDs []string) ([]domain.LogEntry, error)
+	GetThresholdMetrics(ctx context.Context, tenantID, jobID string, scope domain.ThresholdScope, scopeValue string) (*domain.ThresholdMetrics, error)
 	GetDashboardData(ctx context.Context, tenantID, jobID string, topN int) (*domain.DashboardData, error)
-	ComputeHealthScore(ctx context.Context, tenantID, jobID string) (*domain.HealthScore, error)
+	ComputeHealthScore(ctx context.Context, tenantID, jobID string, profile *domain.HealthProfile, restarts []domain.RestartEvent) (*domain.HealthScore, error)
 	GetAggregates(ctx context.Context, tenantID, jobID string) (*domain.AggregatesResponse, error)
+	GetAggregatesPage(ctx context.Context, tenantID, jobID string, page domain.AggregatePage) (*domain.AggregatesResponse, error)
 	GetExceptions(ctx context.Context, tenantID, jobID string) (*domain.ExceptionsResponse, error)
+	GetErrorHeatmap(ctx context.Context, tenantID, jobID, bucket string, limit int) (*domain.ErrorHeatmapResponse, error)
 	GetGaps(ctx context.Context, tenantID, jobID string) (*domain.GapsResponse, error)
 	GetThreadStats(ctx context.Context, tenantID, jobID string) (*domain.ThreadStatsResponse, error)
-	GetFilterComplexity(ctx context.Context, tenantID, jobID string) (*domain.FilterComplexityResponse, error)
+	GetQueueLoad(ctx context.Context, tenantID, jobID string) ([]domain.QueueLoad, error)
+	GetThreadTimeline(ctx context.Context, tenantID, jobID string, q domain.ThreadTimelineQuery) ([]domain.ThreadTimeline, error)
+	GetErrorOnset(ctx context.Context, tenantID, jobID string) (*domain.ErrorOnset, error)
+	GetFocusMetrics(ctx context.Context, tenantID, jobID string, from, to time.Time) (*domain.FocusMetrics, error)
+	GetSQLTableDrilldown(ctx context.Context, tenantID, jobID, table string) (*domain.SQLTableDrilldown, error)
+	GetFilterComplexity(ctx context.Context, tenantID, jobID string, detail bool) (*domain.FilterComplexityResponse, error)
 	SearchEntries(ctx context.Context, tenantID, jobID string, q SearchQuery) (*SearchResult, error)
 	GetHistogramData(ctx context.Context, tenantID, jobID string, timeFrom, timeTo time.Time) (*domain.HistogramResponse, error)
 	GetEntryContext(ctx context.Context, tenantID, jobID, entryID string, window int) (*domain.ContextResponse, error)
 	GetAutocompleteValues(ctx context.Context, tenantID, jobID, field, prefix string, limit int) ([]domain.AutocompleteValue, error)
+	GetJobVocabulary(ctx context.Context, tenantID, jobID string, limit int) (map[string][]domain.AutocompleteValue, error)
 	GetTraceEntries(ctx context.Context, tenantID, jobID, traceID string) ([]domain.LogEntry, error)
+	GetTraceEntriesStream(ctx context.Context, tenantID, jobID, traceID string, q TraceQuery, fn func(domain.LogEntry) error) error
 	GetJobTimeRange(ctx context.Context, tenantID, jobID string) (*JobTimeRange, error)
 	GetFacets(ctx context.Context, tenantID, jobID string, q SearchQuery) (map[string][]FacetValue, error)
+	GetRefinementStats(ctx context.Context, tenantID, jobID string, q SearchQuery) (*RefinementStats, error)
 	SearchTransactions(ctx context.Context, tenantID, jobID string, params domain.TransactionSearchParams) (*domain.TransactionSearchResponse, error)
 	QueryDelayedEscalations(ctx context.Context, tenantID, jobID string, minDelayMS int, limit int) ([]domain.DelayedEscalationEntry, error)
+	EnsureRetentionTTL(ctx context.Context, tiering TieringConfig) (bool, error)
+	UpdateTenantRetentionClass(ctx context.Context, tenantID string, class domain.RetentionClass) error
+	GetTenantStorage(ctx context.Context) ([]domain.TenantStorage, error)
+	CountJobEntries(ctx context.Context, tenantID, jobID string) (int64, error)
+	CountJobEntriesByType(ctx context.Context, tenantID, jobID string) (map[domain.LogType]int64, error)
+	GetSourceOverlay(ctx context.Context, tenantID, jobID string, maxEvents int) (*domain.SourceOverlay, error)
+	CountIngestionFilterMatches(ctx context.Context, tenantID, jobID string, rule domain.IngestionFilterRule) (int64, error)
+	KillQuery(ctx context.Context, id string) error
+	GetDurationPercentile(ctx context.Context, tenantID, jobID string, logType domain.LogType, scope, value string, durationMS uint32) (*domain.DurationPercentile, error)
+	DeleteJobEntries(ctx context.Context, tenantID, jobID string) error
 	Close() error
 }
 
@@ -65,7 +224,43 @@ type RedisCache interface {
 	CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
 }
 
-type S3Storage interface {
+// CacheUsageStore reports the Redis memory the tenant caches take.
+type CacheUsageStore interface {
+	TenantCacheUsage(ctx context.Context, tenantID string) (*domain.TenantCacheUsage, error)
+	AllTenantsCacheUsage(ctx context.Context) ([]domain.TenantCacheUsage, error)
+}
+
+// QuerySlotStore holds the per-tenant semaphore of in-flight search and
+// analytics queries.
+type QuerySlotStore interface {
+	AcquireQuerySlot(ctx context.Context, q domain.InFlightQuery, limit int, lease time.Duration) (bool, error)
+	ReleaseQuerySlot(ctx context.Context, tenantID, id string) error
+	ListQuerySlots(ctx context.Context, tenantID string) ([]domain.InFlightQuery, error)
+}
+
+// UploadSlotStore holds the per-tenant semaphore of in-flight file uploads.
+type UploadSlotStore interface {
+	AcquireUploadSlot(ctx context.Context, u domain.InFlightUpload, limit int, lease time.Duration) (bool, error)
+	RenewUploadSlot(ctx context.Context, tenantID, id string, lease time.Duration) error
+	ReleaseUploadSlot(ctx context.Context, tenantID, id string) error
+	ListUploadSlots(ctx context.Context, tenantID string) ([]domain.InFlightUpload, error)
+}
+
+// ObjectStorage stores uploaded files and generated artifacts. Backends that
+// cannot hand out pre-signed URLs return ErrPresignUnsupported from
+// PresignGetURL; callers then serve the object through the API instead.
+type ObjectStorage interface {
 	Upload(ctx context.Context, key string, reader io.Reader, size int64) error
 	Download(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// RegionResolver resolves the data residency region of a tenant and the
+// object storage of that region. An empty region is the default storage.
+type RegionResolver interface {
+	TenantRegion(ctx context.Context, tenantID string) (string, error)
+	RefreshTenant(ctx context.Context, tenantID string) (string, error)
+	ObjectStorage(ctx context.Context, tenantID string) (ObjectStorage, error)
 }
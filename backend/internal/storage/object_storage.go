@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Object storage backends selectable with ObjectStorageConfig.Backend.
+const (
+	BackendS3         = "s3"
+	BackendAzure      = "azure"
+	BackendGCS        = "gcs"
+	BackendFilesystem = "filesystem"
+)
+
+// ErrPresignUnsupported is returned by PresignGetURL on backends that cannot
+// issue pre-signed URLs.
+var ErrPresignUnsupported = errors.New("storage: backend does not support pre-signed URLs")
+
+// ObjectStorageConfig selects an object storage backend and holds the
+// settings of each. Only the settings of the selected backend are used.
+type ObjectStorageConfig struct {
+	Backend string
+
+	// S3 / MinIO
+	S3Endpoint               string
+	S3AccessKey              string
+	S3SecretKey              string
+	S3Bucket                 string
+	S3UseSSL                 bool
+	S3SkipBucketVerification bool
+
+	// Azure Blob Storage
+	AzureEndpoint   string // defaults to the account's public endpoint
+	AzureAccount    string
+	AzureAccountKey string
+	AzureContainer  string
+
+	// Google Cloud Storage, through its XML API with an HMAC key
+	GCSEndpoint  string // defaults to DefaultGCSEndpoint
+	GCSAccessKey string
+	GCSSecretKey string
+	GCSBucket    string
+
+	// Local filesystem
+	FilesystemRoot string
+}
+
+// NewObjectStorage creates the backend selected by cfg.Backend; an empty
+// backend selects S3.
+func NewObjectStorage(ctx context.Context, cfg ObjectStorageConfig) (ObjectStorage, error) {
+	switch cfg.Backend {
+	case BackendS3, "":
+		return NewS3Client(ctx, cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3UseSSL, cfg.S3SkipBucketVerification)
+	case BackendAzure:
+		return NewAzureBlobClient(cfg.AzureEndpoint, cfg.AzureAccount, cfg.AzureAccountKey, cfg.AzureContainer)
+	case BackendGCS:
+		return NewGCSClient(cfg.GCSEndpoint, cfg.GCSAccessKey, cfg.GCSSecretKey, cfg.GCSBucket)
+	case BackendFilesystem:
+		return NewFilesystemStorage(cfg.FilesystemRoot)
+	default:
+		return nil, fmt.Errorf("storage: unknown object storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,44 @@
+//go:build integration
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func conformancePrefix() string {
+	return fmt.Sprintf("conformance-test/%d", time.Now().UnixNano())
+}
+
+func TestS3_Conformance(t *testing.T) {
+	testObjectStorageConformance(t, setupS3(t), conformancePrefix())
+}
+
+func TestAzureBlob_Conformance(t *testing.T) {
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	key := os.Getenv("AZURE_STORAGE_KEY")
+	container := os.Getenv("AZURE_STORAGE_CONTAINER")
+	if account == "" || key == "" || container == "" {
+		t.Skip("AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and AZURE_STORAGE_CONTAINER not set")
+	}
+	client, err := NewAzureBlobClient(os.Getenv("AZURE_STORAGE_ENDPOINT"), account, key, container)
+	require.NoError(t, err)
+	testObjectStorageConformance(t, client, conformancePrefix())
+}
+
+func TestGCS_Conformance(t *testing.T) {
+	accessKey := os.Getenv("GCS_ACCESS_KEY")
+	secretKey := os.Getenv("GCS_SECRET_KEY")
+	bucket := os.Getenv("GCS_BUCKET")
+	if accessKey == "" || secretKey == "" || bucket == "" {
+		t.Skip("GCS_ACCESS_KEY, GCS_SECRET_KEY and GCS_BUCKET not set")
+	}
+	client, err := NewGCSClient(os.Getenv("GCS_ENDPOINT"), accessKey, secretKey, bucket)
+	require.NoError(t, err)
+	testObjectStorageConformance(t, client, conformancePrefix())
+}
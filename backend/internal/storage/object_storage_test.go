@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testObjectStorageConformance checks the behaviour every ObjectStorage
+// backend must share. prefix isolates the keys of one run.
+func testObjectStorageConformance(t *testing.T, store ObjectStorage, prefix string) {
+	ctx := context.Background()
+	key := func(name string) string { return prefix + "/" + name }
+
+	t.Run("upload and download", func(t *testing.T) {
+		content := []byte("line 1\nline 2\n")
+		require.NoError(t, store.Upload(ctx, key("known-size.log"), bytes.NewReader(content), int64(len(content))))
+		assert.Equal(t, content, download(t, store, key("known-size.log")))
+	})
+
+	t.Run("upload of unknown size", func(t *testing.T) {
+		content := bytes.Repeat([]byte("0123456789"), 100_000)
+		require.NoError(t, store.Upload(ctx, key("unknown-size.bin"), bytes.NewReader(content), -1))
+		assert.Equal(t, content, download(t, store, key("unknown-size.bin")))
+	})
+
+	t.Run("empty object", func(t *testing.T) {
+		require.NoError(t, store.Upload(ctx, key("empty"), bytes.NewReader(nil), 0))
+		assert.Empty(t, download(t, store, key("empty")))
+	})
+
+	t.Run("overwrite replaces content", func(t *testing.T) {
+		require.NoError(t, store.Upload(ctx, key("overwrite"), strings.NewReader("first version"), 13))
+		require.NoError(t, store.Upload(ctx, key("overwrite"), strings.NewReader("second"), 6))
+		assert.Equal(t, "second", string(download(t, store, key("overwrite"))))
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		require.NoError(t, store.Upload(ctx, key("exists"), strings.NewReader("x"), 1))
+		ok, err := store.Exists(ctx, key("exists"))
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = store.Exists(ctx, key("missing"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("download of a missing object fails", func(t *testing.T) {
+		_, err := store.Download(ctx, key("missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.Upload(ctx, key("delete"), strings.NewReader("x"), 1))
+		require.NoError(t, store.Delete(ctx, key("delete")))
+		ok, err := store.Exists(ctx, key("delete"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		assert.NoError(t, store.Delete(ctx, key("delete")), "deleting a missing object is not an error")
+	})
+
+	t.Run("presigned URL or unsupported", func(t *testing.T) {
+		require.NoError(t, store.Upload(ctx, key("presign.txt"), strings.NewReader("signed"), 6))
+		url, err := store.PresignGetURL(ctx, key("presign.txt"), time.Minute)
+		if errors.Is(err, ErrPresignUnsupported) {
+			assert.Empty(t, url)
+			return
+		}
+		require.NoError(t, err)
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "signed", string(body))
+	})
+
+	for _, name := range []string{"known-size.log", "unknown-size.bin", "empty", "overwrite", "exists", "presign.txt"} {
+		_ = store.Delete(ctx, key(name))
+	}
+}
+
+func download(t *testing.T, store ObjectStorage, key string) []byte {
+	t.Helper()
+	r, err := store.Download(context.Background(), key)
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return b
+}
+
+func TestFilesystemStorage_Conformance(t *testing.T) {
+	store, err := NewFilesystemStorage(t.TempDir())
+	require.NoError(t, err)
+	testObjectStorageConformance(t, store, "tenants/t1/jobs/j1")
+}
+
+func TestFilesystemStorage_RejectsEscapingKeys(t *testing.T) {
+	store, err := NewFilesystemStorage(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../outside", "tenants/../../outside", "/absolute", "a//b", `a\b`} {
+		err := store.Upload(context.Background(), key, strings.NewReader("x"), 1)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestAzureBlobClient_Conformance(t *testing.T) {
+	srv := newFakeBlobService(t, "devstoreaccount1", "logs")
+	defer srv.Close()
+
+	key := base64.StdEncoding.EncodeToString([]byte("not-a-real-key"))
+	client, err := NewAzureBlobClient(srv.URL+"/devstoreaccount1", "devstoreaccount1", key, "logs")
+	require.NoError(t, err)
+	testObjectStorageConformance(t, client, "tenants/t1/jobs/j1")
+}
+
+func TestAzureBlobClient_LargeUploadUsesBlocks(t *testing.T) {
+	srv := newFakeBlobService(t, "acct", "logs")
+	defer srv.Close()
+
+	client, err := NewAzureBlobClient(srv.URL+"/acct", "acct", base64.StdEncoding.EncodeToString([]byte("k")), "logs")
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte("a"), azureBlockSize*2+10)
+	require.NoError(t, client.Upload(context.Background(), "big.bin", bytes.NewReader(content), -1))
+	assert.Equal(t, 3, srv.blocksCommitted)
+	assert.Equal(t, content, download(t, client, "big.bin"))
+}
+
+func TestNewObjectStorage_SelectsBackend(t *testing.T) {
+	store, err := NewObjectStorage(context.Background(), ObjectStorageConfig{Backend: BackendFilesystem, FilesystemRoot: t.TempDir()})
+	require.NoError(t, err)
+	assert.IsType(t, &FilesystemStorage{}, store)
+
+	store, err = NewObjectStorage(context.Background(), ObjectStorageConfig{
+		Backend: BackendGCS, GCSAccessKey: "GOOG1", GCSSecretKey: "secret", GCSBucket: "logs",
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &S3Client{}, store)
+
+	_, err = NewObjectStorage(context.Background(), ObjectStorageConfig{Backend: "ftp"})
+	assert.ErrorContains(t, err, "unknown object storage backend")
+}
+
+// fakeBlobService implements the parts of the Azure Blob REST API the
+// client uses: Put Blob, Put Block, Put Block List, Get, Head and Delete.
+// It checks that requests are signed but not the signature itself.
+type fakeBlobService struct {
+	*httptest.Server
+	mu              sync.Mutex
+	blobs           map[string][]byte
+	blocks          map[string][]byte
+	blocksCommitted int
+}
+
+func newFakeBlobService(t *testing.T, account, container string) *fakeBlobService {
+	f := &fakeBlobService{blobs: map[string][]byte{}, blocks: map[string][]byte{}}
+	prefix := "/" + account + "/" + container + "/"
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		q := r.URL.Query()
+		signed := strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey "+account+":") && r.Header.Get("x-ms-version") != ""
+		presigned := r.Method == http.MethodGet && q.Get("sig") != "" && q.Get("sp") == "r"
+		if !signed && !presigned {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			w.Header().Set("x-ms-error-code", "ContainerNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		body, _ := io.ReadAll(r.Body)
+
+		switch {
+		case r.Method == http.MethodPut && q.Get("comp") == "block":
+			f.blocks[name+"/"+q.Get("blockid")] = body
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+			var list struct {
+				Latest []string `xml:"Latest"`
+			}
+			if err := xml.Unmarshal(body, &list); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var blob []byte
+			for _, id := range list.Latest {
+				blob = append(blob, f.blocks[name+"/"+id]...)
+			}
+			f.blobs[name] = blob
+			f.blocksCommitted += len(list.Latest)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" || r.ContentLength < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f.blobs[name] = body
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			blob, ok := f.blobs[name]
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(blob)
+			}
+		case r.Method == http.MethodDelete:
+			if _, ok := f.blobs[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.blobs, name)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return f
+}
@@ -2,12 +2,15 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
@@ -26,10 +29,28 @@ func IsNotFound(err error) bool {
 	return strings.Contains(err.Error(), "not found")
 }
 
+// ErrVersionConflict is returned by optimistic updates whose version
+// precondition no longer holds.
+var ErrVersionConflict = errors.New("postgres: version conflict")
+
+// ErrAlreadyExists is returned by inserts that collide with a unique
+// constraint.
+var ErrAlreadyExists = errors.New("postgres: already exists")
+
+// ErrJobCancelled is returned by UpdateJobStatus when the job was cancelled,
+// or its cancellation requested, before the update: such a job can only
+// end cancelled.
+var ErrJobCancelled = errors.New("postgres: job cancelled")
+
 // PostgresClient wraps a pgx connection pool and provides CRUD operations
 // for all relational data managed in PostgreSQL.
 type PostgresClient struct {
 	pool *pgxpool.Pool
+
+	// replica is the read replica of the read-only methods, used while
+	// replicaHealth allows it. Nil without one.
+	replica       pgReplica
+	replicaHealth *replicaHealth
 }
 
 // NewPostgresClient creates a new PostgreSQL client from the given DSN.
@@ -52,9 +73,12 @@ func NewPostgresClient(ctx context.Context, dsn string) (*PostgresClient, error)
 	return &PostgresClient{pool: pool}, nil
 }
 
-// Close releases all connections in the pool.
+// Close releases all connections in the pool and that of the replica.
 func (p *PostgresClient) Close() {
 	p.pool.Close()
+	if p.replica != nil {
+		p.replica.Close()
+	}
 }
 
 // Ping verifies connectivity to PostgreSQL.
@@ -94,24 +118,47 @@ func (p *PostgresClient) CreateTenant(ctx context.Context, t *domain.Tenant) err
 	now := time.Now().UTC()
 	t.CreatedAt = now
 	t.UpdatedAt = now
+	// A new tenant has no stored entries, so its class applies at once.
+	t.RetentionClass = RetentionClassForTenant(t)
+	if t.SQLRedaction == "" {
+		t.SQLRedaction = domain.SQLRedactionOff
+	}
 
 	_, err := p.pool.Exec(ctx, `
-		INSERT INTO tenants (id, clerk_org_id, name, plan, storage_limit_gb, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, t.ID, t.ClerkOrgID, t.Name, t.Plan, t.StorageLimitGB, t.CreatedAt, t.UpdatedAt)
+		INSERT INTO tenants (id, clerk_org_id, name, plan, storage_limit_gb, retention_class, region,
+			sandbox, sandbox_expires_at, created_at, updated_at, sql_redaction)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, t.ID, t.ClerkOrgID, t.Name, t.Plan, t.StorageLimitGB, t.RetentionClass, t.Region,
+		t.Sandbox, t.SandboxExpiresAt, t.CreatedAt, t.UpdatedAt, t.SQLRedaction)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrAlreadyExists
+		}
 		return fmt.Errorf("postgres: create tenant: %w", err)
 	}
 	return nil
 }
 
+// tenantColumns are the tenants columns read by scanTenant.
+const tenantColumns = `
+	id, clerk_org_id, name, plan, storage_limit_gb, retention_class, region,
+	sandbox, sandbox_expires_at, archived_at, created_at, updated_at, sql_redaction`
+
+func scanTenant(row pgx.Row, t *domain.Tenant) error {
+	return row.Scan(
+		&t.ID, &t.ClerkOrgID, &t.Name, &t.Plan, &t.StorageLimitGB, &t.RetentionClass, &t.Region,
+		&t.Sandbox, &t.SandboxExpiresAt, &t.ArchivedAt, &t.CreatedAt, &t.UpdatedAt, &t.SQLRedaction,
+	)
+}
+
 // GetTenant fetches a tenant by its primary key.
 func (p *PostgresClient) GetTenant(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
 	var t domain.Tenant
-	err := p.pool.QueryRow(ctx, `
-		SELECT id, clerk_org_id, name, plan, storage_limit_gb, created_at, updated_at
+	err := scanTenant(p.pool.QueryRow(ctx, `
+		SELECT`+tenantColumns+`
 		FROM tenants WHERE id = $1
-	`, id).Scan(&t.ID, &t.ClerkOrgID, &t.Name, &t.Plan, &t.StorageLimitGB, &t.CreatedAt, &t.UpdatedAt)
+	`, id), &t)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, fmt.Errorf("postgres: tenant not found: %s", id)
@@ -124,10 +171,10 @@ func (p *PostgresClient) GetTenant(ctx context.Context, id uuid.UUID) (*domain.T
 // GetTenantByClerkOrg looks up a tenant by its Clerk organization ID.
 func (p *PostgresClient) GetTenantByClerkOrg(ctx context.Context, clerkOrgID string) (*domain.Tenant, error) {
 	var t domain.Tenant
-	err := p.pool.QueryRow(ctx, `
-		SELECT id, clerk_org_id, name, plan, storage_limit_gb, created_at, updated_at
+	err := scanTenant(p.pool.QueryRow(ctx, `
+		SELECT`+tenantColumns+`
 		FROM tenants WHERE clerk_org_id = $1
-	`, clerkOrgID).Scan(&t.ID, &t.ClerkOrgID, &t.Name, &t.Plan, &t.StorageLimitGB, &t.CreatedAt, &t.UpdatedAt)
+	`, clerkOrgID), &t)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, fmt.Errorf("postgres: tenant not found for clerk org: %s", clerkOrgID)
@@ -147,14 +194,17 @@ func (p *PostgresClient) CreateLogFile(ctx context.Context, f *domain.LogFile) e
 		f.ID = uuid.New()
 	}
 	f.UploadedAt = time.Now().UTC()
+	if f.SourceType == "" {
+		f.SourceType = domain.LogSourceARServer
+	}
 
 	_, err := p.pool.Exec(ctx, `
 		INSERT INTO log_files (
 			id, tenant_id, filename, size_bytes, s3_key, s3_bucket,
-			content_type, detected_types, checksum_sha256, uploaded_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			content_type, detected_types, checksum_sha256, uploaded_at, source_type
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`, f.ID, f.TenantID, f.Filename, f.SizeBytes, f.S3Key, f.S3Bucket,
-		f.ContentType, f.DetectedTypes, f.ChecksumSHA256, f.UploadedAt)
+		f.ContentType, f.DetectedTypes, f.ChecksumSHA256, f.UploadedAt, f.SourceType)
 	if err != nil {
 		return fmt.Errorf("postgres: create log file: %w", err)
 	}
@@ -166,12 +216,12 @@ func (p *PostgresClient) GetLogFile(ctx context.Context, tenantID, fileID uuid.U
 	var f domain.LogFile
 	err := p.pool.QueryRow(ctx, `
 		SELECT id, tenant_id, filename, size_bytes, s3_key, s3_bucket,
-		       content_type, detected_types, checksum_sha256, uploaded_at
+		       content_type, detected_types, checksum_sha256, uploaded_at, source_type
 		FROM log_files
 		WHERE id = $1 AND tenant_id = $2
 	`, fileID, tenantID).Scan(
 		&f.ID, &f.TenantID, &f.Filename, &f.SizeBytes, &f.S3Key, &f.S3Bucket,
-		&f.ContentType, &f.DetectedTypes, &f.ChecksumSHA256, &f.UploadedAt,
+		&f.ContentType, &f.DetectedTypes, &f.ChecksumSHA256, &f.UploadedAt, &f.SourceType,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -182,11 +232,27 @@ func (p *PostgresClient) GetLogFile(ctx context.Context, tenantID, fileID uuid.U
 	return &f, nil
 }
 
+// DeleteLogFile deletes the record of a log file no analysis refers to.
+func (p *PostgresClient) DeleteLogFile(ctx context.Context, tenantID, fileID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM log_files
+		WHERE id = $1 AND tenant_id = $2
+			AND NOT EXISTS (SELECT 1 FROM analysis_jobs WHERE file_id = $1)
+	`, fileID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: delete log file: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: log file not found: %s", fileID)
+	}
+	return nil
+}
+
 // ListLogFiles returns all log files for a tenant, ordered by upload date descending.
 func (p *PostgresClient) ListLogFiles(ctx context.Context, tenantID uuid.UUID) ([]domain.LogFile, error) {
 	rows, err := p.pool.Query(ctx, `
 		SELECT id, tenant_id, filename, size_bytes, s3_key, s3_bucket,
-		       content_type, detected_types, checksum_sha256, uploaded_at
+		       content_type, detected_types, checksum_sha256, uploaded_at, source_type
 		FROM log_files
 		WHERE tenant_id = $1
 		ORDER BY uploaded_at DESC
@@ -201,7 +267,7 @@ func (p *PostgresClient) ListLogFiles(ctx context.Context, tenantID uuid.UUID) (
 		var f domain.LogFile
 		if err := rows.Scan(
 			&f.ID, &f.TenantID, &f.Filename, &f.SizeBytes, &f.S3Key, &f.S3Bucket,
-			&f.ContentType, &f.DetectedTypes, &f.ChecksumSHA256, &f.UploadedAt,
+			&f.ContentType, &f.DetectedTypes, &f.ChecksumSHA256, &f.UploadedAt, &f.SourceType,
 		); err != nil {
 			return nil, fmt.Errorf("postgres: scan log file: %w", err)
 		}
@@ -210,6 +276,229 @@ func (p *PostgresClient) ListLogFiles(ctx context.Context, tenantID uuid.UUID) (
 	return files, rows.Err()
 }
 
+// ListTenants returns every tenant not archived, ordered by name. It is used by
+// platform-wide tasks and is not tenant-scoped.
+func (p *PostgresClient) ListTenants(ctx context.Context) ([]domain.Tenant, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+tenantColumns+`
+		FROM tenants
+		WHERE archived_at IS NULL
+		ORDER BY name, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []domain.Tenant
+	for rows.Next() {
+		var t domain.Tenant
+		if err := scanTenant(rows, &t); err != nil {
+			return nil, fmt.Errorf("postgres: scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// UpdateTenantRetentionClass records the retention class applied to a
+// tenant's stored log entries.
+func (p *PostgresClient) UpdateTenantRetentionClass(ctx context.Context, id uuid.UUID, class domain.RetentionClass) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE tenants SET retention_class = $1, updated_at = $2 WHERE id = $3
+	`, class, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("postgres: update tenant retention class: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: tenant not found: %s", id)
+	}
+	return nil
+}
+
+// SetTenantSQLRedaction sets the SQL redaction policy of a tenant.
+func (p *PostgresClient) SetTenantSQLRedaction(ctx context.Context, id uuid.UUID, policy domain.SQLRedaction) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE tenants SET sql_redaction = $1, updated_at = $2 WHERE id = $3
+	`, policy, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("postgres: set tenant sql redaction: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: tenant not found: %s", id)
+	}
+	return nil
+}
+
+// SetTenantRegion pins a tenant's data to a storage region, empty for the
+// default storage, and routes the tenant to the ClickHouse cluster of the
+// region in the same transaction.
+func (p *PostgresClient) SetTenantRegion(ctx context.Context, id uuid.UUID, region string) error {
+	cluster := region
+	if cluster == "" {
+		cluster = DefaultClickHouseCluster
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: set tenant region begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE tenants SET region = $1, updated_at = $2 WHERE id = $3
+	`, region, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("postgres: set tenant region: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: tenant not found: %s", id)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO tenant_clickhouse_routes (tenant_id, cluster, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (tenant_id) DO UPDATE SET cluster = EXCLUDED.cluster, updated_at = NOW()
+	`, id, cluster); err != nil {
+		return fmt.Errorf("postgres: set tenant region route: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: set tenant region commit: %w", err)
+	}
+	return nil
+}
+
+// TenantHasData reports whether a tenant has uploaded files or analyses,
+// trashed ones included.
+func (p *PostgresClient) TenantHasData(ctx context.Context, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := p.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM log_files WHERE tenant_id = $1)
+		    OR EXISTS (SELECT 1 FROM analysis_jobs WHERE tenant_id = $1)
+	`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("postgres: tenant has data: %w", err)
+	}
+	return exists, nil
+}
+
+// ErrNotSandbox is returned when converting a tenant that is not a live
+// sandbox: a full tenant, or a sandbox already expired or archived.
+var ErrNotSandbox = errors.New("postgres: tenant is not a live sandbox")
+
+// ListExpiredSandboxTenants returns the sandbox tenants that expired at or
+// before now and are not archived yet, oldest expiry first.
+func (p *PostgresClient) ListExpiredSandboxTenants(ctx context.Context, now time.Time) ([]domain.Tenant, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+tenantColumns+`
+		FROM tenants
+		WHERE sandbox AND archived_at IS NULL AND sandbox_expires_at <= $1
+		ORDER BY sandbox_expires_at, id
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list expired sandbox tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []domain.Tenant
+	for rows.Next() {
+		var t domain.Tenant
+		if err := scanTenant(rows, &t); err != nil {
+			return nil, fmt.Errorf("postgres: scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// ConvertSandboxTenant makes a live sandbox a full tenant, lifting its
+// quotas and expiry and clearing the sandbox flag of its analyses. Its data
+// is kept. It returns ErrNotSandbox when the tenant is not a live sandbox.
+func (p *PostgresClient) ConvertSandboxTenant(ctx context.Context, id uuid.UUID, now time.Time) (*domain.Tenant, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: convert sandbox tenant begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var t domain.Tenant
+	err = scanTenant(tx.QueryRow(ctx, `
+		UPDATE tenants SET sandbox = FALSE, sandbox_expires_at = NULL, updated_at = $2
+		WHERE id = $1 AND sandbox AND archived_at IS NULL AND sandbox_expires_at > $2
+		RETURNING`+tenantColumns, id, now), &t)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: convert sandbox tenant: %w", err)
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM tenants WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("postgres: convert sandbox tenant: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("postgres: tenant not found: %s", id)
+		}
+		return nil, ErrNotSandbox
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE analysis_jobs SET sandbox = FALSE WHERE tenant_id = $1 AND sandbox
+	`, id); err != nil {
+		return nil, fmt.Errorf("postgres: convert sandbox jobs: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("postgres: convert sandbox tenant commit: %w", err)
+	}
+	return &t, nil
+}
+
+// ArchiveTenant archives a tenant whose analyses were purged, deleting the
+// records of the files it uploaded but never analysed. It returns the keys
+// of those files' objects, for the caller to delete.
+func (p *PostgresClient) ArchiveTenant(ctx context.Context, id uuid.UUID, now time.Time) ([]string, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: archive tenant begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE tenants SET archived_at = $2, updated_at = $2 WHERE id = $1 AND archived_at IS NULL
+	`, id, now)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: archive tenant: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("postgres: tenant not found: %s", id)
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM log_files
+		WHERE tenant_id = $1 AND NOT EXISTS (SELECT 1 FROM analysis_jobs WHERE file_id = log_files.id)
+		RETURNING s3_key
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: archive tenant files: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("postgres: archive tenant files scan: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: archive tenant files: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("postgres: archive tenant commit: %w", err)
+	}
+	return keys, nil
+}
+
 // --------------------------------------------------------------------------
 // Analysis Jobs
 // --------------------------------------------------------------------------
@@ -225,40 +514,62 @@ func (p *PostgresClient) CreateJob(ctx context.Context, j *domain.AnalysisJob) e
 
 	_, err := p.pool.Exec(ctx, `
 		INSERT INTO analysis_jobs (
-			id, tenant_id, status, file_id, jar_flags, jvm_heap_mb,
-			timeout_seconds, progress_pct, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, j.ID, j.TenantID, j.Status, j.FileID, j.JARFlags, j.JVMHeapMB,
-		j.TimeoutSeconds, j.ProgressPct, j.CreatedAt, j.UpdatedAt)
+			id, tenant_id, status, priority, file_id, jar_flags, jvm_heap_mb,
+			timeout_seconds, progress_pct, correct_clock_skew, sampling, sandbox, estimate, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, j.ID, j.TenantID, j.Status, j.Priority.OrDefault(), j.FileID, j.JARFlags, j.JVMHeapMB,
+		j.TimeoutSeconds, j.ProgressPct, j.CorrectClockSkew, j.Sampling, j.Sandbox, j.Estimate, j.CreatedAt, j.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("postgres: create job: %w", err)
 	}
 	return nil
 }
 
-// GetJob retrieves an analysis job by its ID within a tenant.
-func (p *PostgresClient) GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, error) {
-	var j domain.AnalysisJob
-	err := p.pool.QueryRow(ctx, `
-		SELECT
-			id, tenant_id, status, file_id, jar_flags, jvm_heap_mb,
-			timeout_seconds, progress_pct,
-			total_lines, processed_lines,
-			api_count, sql_count, filter_count, esc_count,
-			start_time, end_time, log_start, log_end, log_duration,
-			error_message, jar_stderr,
-			created_at, updated_at, completed_at
-		FROM analysis_jobs
-		WHERE id = $1 AND tenant_id = $2
-	`, jobID, tenantID).Scan(
-		&j.ID, &j.TenantID, &j.Status, &j.FileID, &j.JARFlags, &j.JVMHeapMB,
+// jobColumns are the analysis_jobs columns read by scanJob.
+const jobColumns = `
+	id, tenant_id, status, priority, file_id, jar_flags, jvm_heap_mb,
+	timeout_seconds, progress_pct,
+	total_lines, processed_lines,
+	api_count, sql_count, filter_count, esc_count,
+	start_time, end_time, log_start, log_end, log_duration,
+	error_message, jar_stderr,
+	peak_rss_kb, cpu_time_ms, wall_time_ms, log_format, violation_count,
+	correct_clock_skew, clock_skew, restarts, thread_counts, ingestion_filter_stats, section_presence, first_error_at,
+	file_integrity, error_code, sampling, data_quality, ingestion_reconciliation, focus_window,
+	capture_profile, capture_drift, capture_baseline,
+	incident_group_id, sandbox, estimate, estimate_accuracy,
+	investigation_status, investigation_notes, investigation_assignee,
+	investigation_version, investigation_updated_at,
+	created_at, updated_at, completed_at, started_at, deleted_at, cancel_requested_at`
+
+func scanJob(row pgx.Row, j *domain.AnalysisJob) error {
+	return row.Scan(
+		&j.ID, &j.TenantID, &j.Status, &j.Priority, &j.FileID, &j.JARFlags, &j.JVMHeapMB,
 		&j.TimeoutSeconds, &j.ProgressPct,
 		&j.TotalLines, &j.ProcessedLines,
 		&j.APICount, &j.SQLCount, &j.FilterCount, &j.EscCount,
 		&j.StartTime, &j.EndTime, &j.LogStart, &j.LogEnd, &j.LogDuration,
 		&j.ErrorMessage, &j.JARStderr,
-		&j.CreatedAt, &j.UpdatedAt, &j.CompletedAt,
+		&j.PeakRSSKB, &j.CPUTimeMS, &j.WallTimeMS, &j.LogFormat, &j.ViolationCount,
+		&j.CorrectClockSkew, &j.ClockSkew, &j.Restarts, &j.ThreadCounts, &j.IngestionFilters, &j.Sections, &j.FirstErrorAt,
+		&j.Integrity, &j.ErrorCode, &j.Sampling, &j.DataQuality, &j.Reconciliation, &j.FocusWindow,
+		&j.CaptureProfile, &j.CaptureDrift, &j.CaptureBaseline,
+		&j.IncidentGroupID, &j.Sandbox, &j.Estimate, &j.EstimateAccuracy,
+		&j.Investigation.Status, &j.Investigation.Notes, &j.Investigation.Assignee,
+		&j.Investigation.Version, &j.Investigation.UpdatedAt,
+		&j.CreatedAt, &j.UpdatedAt, &j.CompletedAt, &j.StartedAt, &j.DeletedAt, &j.CancelRequestedAt,
 	)
+}
+
+// GetJob retrieves an analysis job by its ID within a tenant. Analyses in
+// the trash are not found.
+func (p *PostgresClient) GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, error) {
+	var j domain.AnalysisJob
+	err := scanJob(p.reader(ctx).QueryRow(ctx, `
+		SELECT`+jobColumns+`
+		FROM analysis_jobs
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+	`, jobID, tenantID), &j)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, fmt.Errorf("postgres: job not found: %s", jobID)
@@ -269,28 +580,78 @@ func (p *PostgresClient) GetJob(ctx context.Context, tenantID, jobID uuid.UUID)
 }
 
 // UpdateJobStatus transitions a job to a new status, updating the timestamp.
-// If the new status is "complete" or "failed", CompletedAt is also set.
+// If the new status is terminal, CompletedAt is also set. The first move to
+// "parsing" sets StartedAt.
+//
+// A cancelled job is never moved again, and once its cancellation was
+// requested a job can only be moved to cancelled: other updates return
+// ErrJobCancelled. This settles a job that finishes while its cancellation
+// is requested: whichever of the completion and RequestJobCancel updates
+// the row first wins, and RequestJobCancel refuses a job already complete
+// or failed.
 func (p *PostgresClient) UpdateJobStatus(ctx context.Context, tenantID, jobID uuid.UUID, status domain.JobStatus, errMsg *string) error {
 	now := time.Now().UTC()
 	var completedAt *time.Time
-	if status == domain.JobStatusComplete || status == domain.JobStatusFailed {
+	if status.Terminal() {
 		completedAt = &now
 	}
 
 	tag, err := p.pool.Exec(ctx, `
 		UPDATE analysis_jobs
-		SET status = $1, error_message = $2, updated_at = $3, completed_at = $4
+		SET status = $1, error_message = $2, updated_at = $3, completed_at = $4,
+		    started_at = CASE WHEN $1 = 'parsing' THEN COALESCE(started_at, $3) ELSE started_at END
 		WHERE id = $5 AND tenant_id = $6
+		  AND status <> 'cancelled'
+		  AND (cancel_requested_at IS NULL OR $1 = 'cancelled')
 	`, status, errMsg, now, completedAt, jobID, tenantID)
 	if err != nil {
 		return fmt.Errorf("postgres: update job status: %w", err)
 	}
 	if tag.RowsAffected() == 0 {
+		var exists bool
+		if err := p.pool.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM analysis_jobs WHERE id = $1 AND tenant_id = $2)
+		`, jobID, tenantID).Scan(&exists); err != nil {
+			return fmt.Errorf("postgres: update job status: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("postgres: update job status: %w", ErrJobCancelled)
+		}
 		return fmt.Errorf("postgres: job not found: %s", jobID)
 	}
 	return nil
 }
 
+// RequestJobCancel records a request to cancel a job. A queued job is
+// cancelled at once, in the same update; a running one keeps its status
+// until its worker stops it. requested is false when the job is already
+// complete, failed or cancelled, in which case the job is returned as it
+// is.
+func (p *PostgresClient) RequestJobCancel(ctx context.Context, tenantID, jobID uuid.UUID) (job *domain.AnalysisJob, requested bool, err error) {
+	var j domain.AnalysisJob
+	err = scanJob(p.pool.QueryRow(ctx, `
+		UPDATE analysis_jobs
+		SET cancel_requested_at = COALESCE(cancel_requested_at, now()),
+		    status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+		    completed_at = CASE WHEN status = 'queued' THEN now() ELSE completed_at END,
+		    error_message = CASE WHEN status = 'queued' THEN 'analysis cancelled' ELSE error_message END,
+		    updated_at = now()
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		  AND status NOT IN ('complete', 'failed', 'cancelled')
+		RETURNING`+jobColumns, jobID, tenantID), &j)
+	if err == nil {
+		return &j, true, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, false, fmt.Errorf("postgres: request job cancel: %w", err)
+	}
+	current, err := p.GetJob(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, false, err
+	}
+	return current, false, nil
+}
+
 // UpdateJobProgress updates the progress percentage and line counters for a job.
 func (p *PostgresClient) UpdateJobProgress(ctx context.Context, tenantID, jobID uuid.UUID, progressPct int, processedLines *int64) error {
 	now := time.Now().UTC()
@@ -308,419 +669,3487 @@ func (p *PostgresClient) UpdateJobProgress(ctx context.Context, tenantID, jobID
 	return nil
 }
 
-// ListJobs returns all analysis jobs for a tenant, ordered by creation date descending.
-func (p *PostgresClient) ListJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error) {
-	rows, err := p.pool.Query(ctx, `
-		SELECT
-			id, tenant_id, status, file_id, jar_flags, jvm_heap_mb,
-			timeout_seconds, progress_pct,
-			total_lines, processed_lines,
-			api_count, sql_count, filter_count, esc_count,
-			start_time, end_time, log_start, log_end, log_duration,
-			error_message, jar_stderr,
-			created_at, updated_at, completed_at
-		FROM analysis_jobs
-		WHERE tenant_id = $1
-		ORDER BY created_at DESC
-	`, tenantID)
+// UpdateJobResources records the peak RSS, CPU time and wall time consumed
+// by the JAR process of a job.
+func (p *PostgresClient) UpdateJobResources(ctx context.Context, tenantID, jobID uuid.UUID, usage domain.JobResourceUsage) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET peak_rss_kb = $1, cpu_time_ms = $2, wall_time_ms = $3, updated_at = $4
+		WHERE id = $5 AND tenant_id = $6
+	`, usage.PeakRSSKB, usage.CPUTimeMS, usage.WallTimeMS, now, jobID, tenantID)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: list jobs: %w", err)
+		return fmt.Errorf("postgres: update job resources: %w", err)
 	}
-	defer rows.Close()
-
-	var jobs []domain.AnalysisJob
-	for rows.Next() {
-		var j domain.AnalysisJob
-		if err := rows.Scan(
-			&j.ID, &j.TenantID, &j.Status, &j.FileID, &j.JARFlags, &j.JVMHeapMB,
-			&j.TimeoutSeconds, &j.ProgressPct,
-			&j.TotalLines, &j.ProcessedLines,
-			&j.APICount, &j.SQLCount, &j.FilterCount, &j.EscCount,
-			&j.StartTime, &j.EndTime, &j.LogStart, &j.LogEnd, &j.LogDuration,
-			&j.ErrorMessage, &j.JARStderr,
-			&j.CreatedAt, &j.UpdatedAt, &j.CompletedAt,
-		); err != nil {
-			return nil, fmt.Errorf("postgres: scan job: %w", err)
-		}
-		jobs = append(jobs, j)
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
 	}
-	return jobs, rows.Err()
+	return nil
 }
 
-// --------------------------------------------------------------------------
-// AI Interactions
-// --------------------------------------------------------------------------
-
-// CreateAIInteraction inserts a new AI interaction record.
-func (p *PostgresClient) CreateAIInteraction(ctx context.Context, ai *domain.AIInteraction) error {
-	if ai.ID == uuid.Nil {
-		ai.ID = uuid.New()
+// UpdateJobLogFormat records the log format detected for a job's input file.
+func (p *PostgresClient) UpdateJobLogFormat(ctx context.Context, tenantID, jobID uuid.UUID, format domain.LogFormat) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET log_format = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, format, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job log format: %w", err)
 	}
-	ai.CreatedAt = time.Now().UTC()
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
 
-	_, err := p.pool.Exec(ctx, `
-		INSERT INTO ai_interactions (
-			id, tenant_id, job_id, user_id, skill_name,
-			input_text, output_text, referenced_lines,
-			tokens_used, latency_ms, status, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`, ai.ID, ai.TenantID, ai.JobID, ai.UserID, ai.SkillName,
-		ai.InputText, ai.OutputText, ai.ReferencedLines,
-		ai.TokensUsed, ai.LatencyMS, ai.Status, ai.CreatedAt)
+// UpdateJobRestarts records the server restarts detected within the
+// capture of a job.
+func (p *PostgresClient) UpdateJobRestarts(ctx context.Context, tenantID, jobID uuid.UUID, restarts []domain.RestartEvent) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET restarts = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, restarts, now, jobID, tenantID)
 	if err != nil {
-		return fmt.Errorf("postgres: create ai interaction: %w", err)
+		return fmt.Errorf("postgres: update job restarts: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
 	}
 	return nil
 }
 
-// UpdateAIInteraction updates the output fields of an AI interaction after
-// the AI skill completes.
-func (p *PostgresClient) UpdateAIInteraction(ctx context.Context, tenantID, interactionID uuid.UUID, outputText *string, tokensUsed *int, latencyMS *int, status string) error {
+// UpdateJobThreadCounts records the threads configured per queue on the
+// server the job's capture was taken on.
+func (p *PostgresClient) UpdateJobThreadCounts(ctx context.Context, tenantID, jobID uuid.UUID, counts map[string]int) error {
+	now := time.Now().UTC()
 	tag, err := p.pool.Exec(ctx, `
-		UPDATE ai_interactions
-		SET output_text = $1, tokens_used = $2, latency_ms = $3, status = $4
-		WHERE id = $5 AND tenant_id = $6
-	`, outputText, tokensUsed, latencyMS, status, interactionID, tenantID)
+		UPDATE analysis_jobs
+		SET thread_counts = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, counts, now, jobID, tenantID)
 	if err != nil {
-		return fmt.Errorf("postgres: update ai interaction: %w", err)
+		return fmt.Errorf("postgres: update job thread counts: %w", err)
 	}
 	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("postgres: ai interaction not found: %s", interactionID)
+		return fmt.Errorf("postgres: job not found: %s", jobID)
 	}
 	return nil
 }
 
-// --------------------------------------------------------------------------
-// Saved Searches
-// --------------------------------------------------------------------------
-
-// CreateSavedSearch inserts a new saved search.
-func (p *PostgresClient) CreateSavedSearch(ctx context.Context, s *domain.SavedSearch) error {
-	if s.ID == uuid.Nil {
-		s.ID = uuid.New()
+// UpdateJobSampling records how a sampled job's capture was ingested: its
+// hot windows and the entries sampled, ingested in full and skipped.
+func (p *PostgresClient) UpdateJobSampling(ctx context.Context, tenantID, jobID uuid.UUID, sampling *domain.Sampling) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET sampling = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, sampling, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job sampling: %w", err)
 	}
-	s.CreatedAt = time.Now().UTC()
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
 
-	_, err := p.pool.Exec(ctx, `
-		INSERT INTO saved_searches (id, tenant_id, user_id, name, kql_query, filters, is_pinned, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, s.ID, s.TenantID, s.UserID, s.Name, s.KQLQuery, s.Filters, s.IsPinned, s.CreatedAt)
+// UpdateJobIngestionFilterStats records the entries each ingestion filter
+// rule matched while the job's capture was stored.
+func (p *PostgresClient) UpdateJobIngestionFilterStats(ctx context.Context, tenantID, jobID uuid.UUID, stats []domain.IngestionFilterMatch) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET ingestion_filter_stats = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, stats, now, jobID, tenantID)
 	if err != nil {
-		return fmt.Errorf("postgres: create saved search: %w", err)
+		return fmt.Errorf("postgres: update job ingestion filter stats: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
 	}
 	return nil
 }
 
-// ListSavedSearches returns all saved searches for a tenant and user.
-func (p *PostgresClient) ListSavedSearches(ctx context.Context, tenantID uuid.UUID, userID string) ([]domain.SavedSearch, error) {
+// UpdateJobClockSkew records the clock skew detected between the files of a
+// multi-file capture.
+func (p *PostgresClient) UpdateJobClockSkew(ctx context.Context, tenantID, jobID uuid.UUID, report *domain.ClockSkewReport) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET clock_skew = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, report, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job clock skew: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobSectionPresence records which log types a job's capture holds.
+func (p *PostgresClient) UpdateJobSectionPresence(ctx context.Context, tenantID, jobID uuid.UUID, sections *domain.SectionPresence) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET section_presence = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, sections, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job section presence: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobParseDiagnostics records the diagnostics of a strict parse of a
+// job's JAR report.
+func (p *PostgresClient) UpdateJobParseDiagnostics(ctx context.Context, tenantID, jobID uuid.UUID, diag *domain.ParseDiagnostics) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET parse_diagnostics = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, diag, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job parse diagnostics: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobReconciliation records the reconciliation of the entries stored
+// for a job with the JAR's counts, and the data quality it found.
+func (p *PostgresClient) UpdateJobReconciliation(ctx context.Context, tenantID, jobID uuid.UUID, rec *domain.IngestionReconciliation) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET data_quality = $1, ingestion_reconciliation = $2, updated_at = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, rec.Quality, rec, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job reconciliation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobFileIntegrity records the integrity check of the uploaded file
+// of a job, and the code of its fatal issue if it has one.
+func (p *PostgresClient) UpdateJobFileIntegrity(ctx context.Context, tenantID, jobID uuid.UUID, report *domain.FileIntegrity) error {
+	var errorCode *string
+	if fatal := report.Fatal(); fatal != nil {
+		errorCode = &fatal.Code
+	}
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET file_integrity = $1, error_code = $2, updated_at = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, report, errorCode, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job file integrity: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobErrorOnset records the error onset of a job, along with the
+// timestamp of its first error.
+func (p *PostgresClient) UpdateJobErrorOnset(ctx context.Context, tenantID, jobID uuid.UUID, onset *domain.ErrorOnset) error {
+	var firstErrorAt *time.Time
+	if onset.FirstError != nil {
+		firstErrorAt = onset.FirstError.Timestamp.TimePtr()
+	}
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET error_onset = $1, first_error_at = $2, updated_at = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, onset, firstErrorAt, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job error onset: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobFocusWindow records the focus window of a job; nil clears it.
+func (p *PostgresClient) UpdateJobFocusWindow(ctx context.Context, tenantID, jobID uuid.UUID, window *domain.FocusWindow) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET focus_window = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, window, time.Now().UTC(), jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job focus window: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// UpdateJobCaptureDrift records how a job's capture was taken and how that
+// differs from its reference capture; a nil drift means there was no
+// reference to check it against.
+func (p *PostgresClient) UpdateJobCaptureDrift(ctx context.Context, tenantID, jobID uuid.UUID, profile *domain.CaptureProfile, drift *domain.CaptureDrift) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET capture_profile = $1, capture_drift = $2, updated_at = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, profile, drift, time.Now().UTC(), jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job capture drift: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// GetCaptureReference returns the analysis the capture of a job is checked
+// against, and which reference it is: the tenant's designated baseline
+// analysis, else its most recently completed analysis of an AR Server log.
+// It returns nil when the tenant has no other such analysis.
+func (p *PostgresClient) GetCaptureReference(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, string, error) {
+	var j domain.AnalysisJob
+	err := scanJob(p.reader(ctx).QueryRow(ctx, `
+		SELECT`+jobColumns+`
+		FROM analysis_jobs j
+		WHERE tenant_id = $1 AND id <> $2 AND deleted_at IS NULL AND status = 'complete'
+			AND EXISTS (
+				SELECT 1 FROM log_files f
+				WHERE f.id = j.file_id AND f.tenant_id = j.tenant_id AND f.source_type = 'ar_server'
+			)
+		ORDER BY capture_baseline DESC, completed_at DESC NULLS LAST
+		LIMIT 1
+	`, tenantID, jobID), &j)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("postgres: get capture reference: %w", err)
+	}
+	if j.CaptureBaseline {
+		return &j, domain.CaptureReferenceBaseline, nil
+	}
+	return &j, domain.CaptureReferencePrevious, nil
+}
+
+// SetCaptureBaseline designates a complete job as the analysis the tenant's
+// later captures are checked against, replacing the tenant's earlier
+// baseline, or clears the designation when baseline is false.
+func (p *PostgresClient) SetCaptureBaseline(ctx context.Context, tenantID, jobID uuid.UUID, baseline bool) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: set capture baseline begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	if baseline {
+		if _, err := tx.Exec(ctx, `
+			UPDATE analysis_jobs
+			SET capture_baseline = false, updated_at = $1
+			WHERE tenant_id = $2 AND capture_baseline AND id <> $3
+		`, now, tenantID, jobID); err != nil {
+			return fmt.Errorf("postgres: clear capture baseline: %w", err)
+		}
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET capture_baseline = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4 AND deleted_at IS NULL
+	`, baseline, now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: set capture baseline: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: set capture baseline commit: %w", err)
+	}
+	return nil
+}
+
+// GetJobErrorOnset returns the error onset of a job, or nil when it has not
+// been computed.
+func (p *PostgresClient) GetJobErrorOnset(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.ErrorOnset, error) {
+	var onset *domain.ErrorOnset
+	err := p.reader(ctx).QueryRow(ctx, `
+		SELECT error_onset FROM analysis_jobs
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+	`, jobID, tenantID).Scan(&onset)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: job not found: %s", jobID)
+		}
+		return nil, fmt.Errorf("postgres: get job error onset: %w", err)
+	}
+	return onset, nil
+}
+
+// UpdateJobAPILegend stores the API abbreviation legend of a job's JAR output.
+func (p *PostgresClient) UpdateJobAPILegend(ctx context.Context, tenantID, jobID uuid.UUID, legend []domain.JARAPIAbbreviation) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET api_legend = $1
+		WHERE id = $2 AND tenant_id = $3
+	`, legend, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job api legend: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// GetJobAPILegend returns the API abbreviation legend of a job, or nil when
+// its JAR output had none.
+func (p *PostgresClient) GetJobAPILegend(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JARAPIAbbreviation, error) {
+	var legend []domain.JARAPIAbbreviation
+	err := p.reader(ctx).QueryRow(ctx, `
+		SELECT api_legend FROM analysis_jobs
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+	`, jobID, tenantID).Scan(&legend)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: job not found: %s", jobID)
+		}
+		return nil, fmt.Errorf("postgres: get job api legend: %w", err)
+	}
+	return legend, nil
+}
+
+// UpdateJobInvestigation applies update to a job's investigation if its
+// version still equals expectedVersion, and records the change in the
+// investigation history. It returns the updated investigation and the
+// history event, or ErrVersionConflict when another update got there first.
+func (p *PostgresClient) UpdateJobInvestigation(ctx context.Context, tenantID, jobID uuid.UUID, expectedVersion int, update domain.InvestigationUpdate, actorID string) (*domain.Investigation, *domain.InvestigationEvent, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("postgres: update investigation begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var cur domain.Investigation
+	err = tx.QueryRow(ctx, `
+		SELECT investigation_status, investigation_notes, investigation_assignee, investigation_version
+		FROM analysis_jobs
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, jobID, tenantID).Scan(&cur.Status, &cur.Notes, &cur.Assignee, &cur.Version)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, fmt.Errorf("postgres: job not found: %s", jobID)
+		}
+		return nil, nil, fmt.Errorf("postgres: update investigation select: %w", err)
+	}
+	if cur.Version != expectedVersion {
+		return nil, nil, ErrVersionConflict
+	}
+
+	next := cur
+	if update.Status != nil {
+		next.Status = *update.Status
+	}
+	notesChanged := false
+	if update.Notes != nil && *update.Notes != cur.Notes {
+		next.Notes = *update.Notes
+		notesChanged = true
+	}
+	if update.Assignee != nil {
+		next.Assignee = nil
+		if *update.Assignee != "" {
+			next.Assignee = update.Assignee
+		}
+	}
+	now := time.Now().UTC()
+	next.Version = cur.Version + 1
+	next.UpdatedAt = &now
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET investigation_status = $1, investigation_notes = $2, investigation_assignee = $3,
+			investigation_version = $4, investigation_updated_at = $5
+		WHERE id = $6 AND tenant_id = $7
+	`, next.Status, next.Notes, next.Assignee, next.Version, now, jobID, tenantID); err != nil {
+		return nil, nil, fmt.Errorf("postgres: update investigation: %w", err)
+	}
+
+	ev := domain.InvestigationEvent{
+		ID:           uuid.New(),
+		TenantID:     tenantID,
+		JobID:        jobID,
+		ActorID:      actorID,
+		FromStatus:   cur.Status,
+		ToStatus:     next.Status,
+		Assignee:     next.Assignee,
+		NotesChanged: notesChanged,
+		Version:      next.Version,
+		CreatedAt:    now,
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO investigation_events (id, tenant_id, job_id, actor_id, from_status, to_status,
+			assignee, notes_changed, version, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, ev.ID, ev.TenantID, ev.JobID, ev.ActorID, ev.FromStatus, ev.ToStatus,
+		ev.Assignee, ev.NotesChanged, ev.Version, ev.CreatedAt); err != nil {
+		return nil, nil, fmt.Errorf("postgres: insert investigation event: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("postgres: update investigation commit: %w", err)
+	}
+	return &next, &ev, nil
+}
+
+// ListInvestigationEvents returns the investigation history of a job, oldest
+// first.
+func (p *PostgresClient) ListInvestigationEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.InvestigationEvent, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT id, tenant_id, job_id, actor_id, from_status, to_status,
+			assignee, notes_changed, version, created_at
+		FROM investigation_events
+		WHERE tenant_id = $1 AND job_id = $2
+		ORDER BY created_at ASC, version ASC
+	`, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list investigation events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.InvestigationEvent{}
+	for rows.Next() {
+		var ev domain.InvestigationEvent
+		if err := rows.Scan(&ev.ID, &ev.TenantID, &ev.JobID, &ev.ActorID, &ev.FromStatus, &ev.ToStatus,
+			&ev.Assignee, &ev.NotesChanged, &ev.Version, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan investigation event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// AppendJobEvents writes job events in one round trip. Events of jobs that
+// no longer exist, such as an analysis purged while its last events were
+// queued, are skipped. It returns the number of events written.
+func (p *PostgresClient) AppendJobEvents(ctx context.Context, events []domain.JobEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(`
+			INSERT INTO job_events (tenant_id, job_id, source, kind, stage, message, metadata, occurred_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8
+			WHERE EXISTS (SELECT 1 FROM analysis_jobs WHERE id = $2 AND tenant_id = $1)
+		`, e.TenantID, e.JobID, e.Source, e.Kind, e.Stage, e.Message, e.Metadata, e.OccurredAt.UTC())
+	}
+
+	br := p.pool.SendBatch(ctx, batch)
+	defer br.Close()
+	written := 0
+	for range events {
+		tag, err := br.Exec()
+		if err != nil {
+			return written, fmt.Errorf("postgres: append job event: %w", err)
+		}
+		written += int(tag.RowsAffected())
+	}
+	return written, nil
+}
+
+// ListJobEvents returns the event log of a job, oldest first. Events with
+// the same timestamp keep the order they were written in.
+func (p *PostgresClient) ListJobEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JobEvent, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT id, tenant_id, job_id, source, kind, stage, message, metadata, occurred_at
+		FROM job_events
+		WHERE tenant_id = $1 AND job_id = $2
+		ORDER BY occurred_at ASC, id ASC
+	`, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list job events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.JobEvent{}
+	for rows.Next() {
+		var ev domain.JobEvent
+		if err := rows.Scan(&ev.ID, &ev.TenantID, &ev.JobID, &ev.Source, &ev.Kind, &ev.Stage,
+			&ev.Message, &ev.Metadata, &ev.OccurredAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan job event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// GetJobCheckpoint retrieves the ingestion checkpoint of a job.
+func (p *PostgresClient) GetJobCheckpoint(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.JobCheckpoint, error) {
+	var cp domain.JobCheckpoint
+	err := p.pool.QueryRow(ctx, `
+		SELECT job_id, tenant_id, run_id, stage, jar_output_key, output_format,
+			next_batch, last_batch_token, cache_sections, updated_at
+		FROM job_checkpoints
+		WHERE tenant_id = $1 AND job_id = $2
+	`, tenantID, jobID).Scan(&cp.JobID, &cp.TenantID, &cp.RunID, &cp.Stage, &cp.JAROutputKey, &cp.OutputFormat,
+		&cp.NextBatch, &cp.LastBatchToken, &cp.CacheSections, &cp.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: job checkpoint not found: %s", jobID)
+		}
+		return nil, fmt.Errorf("postgres: get job checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// SaveJobCheckpoint creates or replaces the ingestion checkpoint of a job
+// and sets cp.UpdatedAt.
+func (p *PostgresClient) SaveJobCheckpoint(ctx context.Context, cp *domain.JobCheckpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	sections := cp.CacheSections
+	if sections == nil {
+		sections = []string{}
+	}
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO job_checkpoints (job_id, tenant_id, run_id, stage, jar_output_key, output_format,
+			next_batch, last_batch_token, cache_sections, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (job_id) DO UPDATE SET
+			run_id = EXCLUDED.run_id,
+			stage = EXCLUDED.stage,
+			jar_output_key = EXCLUDED.jar_output_key,
+			output_format = EXCLUDED.output_format,
+			next_batch = EXCLUDED.next_batch,
+			last_batch_token = EXCLUDED.last_batch_token,
+			cache_sections = EXCLUDED.cache_sections,
+			updated_at = EXCLUDED.updated_at
+	`, cp.JobID, cp.TenantID, cp.RunID, cp.Stage, cp.JAROutputKey, cp.OutputFormat,
+		cp.NextBatch, cp.LastBatchToken, sections, cp.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: save job checkpoint: %w", err)
+	}
+	return nil
+}
+
+// DeleteJobCheckpoint removes the ingestion checkpoint of a job. Deleting a
+// job without one is not an error.
+func (p *PostgresClient) DeleteJobCheckpoint(ctx context.Context, tenantID, jobID uuid.UUID) error {
+	if _, err := p.pool.Exec(ctx, `
+		DELETE FROM job_checkpoints WHERE tenant_id = $1 AND job_id = $2
+	`, tenantID, jobID); err != nil {
+		return fmt.Errorf("postgres: delete job checkpoint: %w", err)
+	}
+	return nil
+}
+
+const supportGrantColumns = `
+	id, tenant_id, granted_by, reason, read_only, created_at, expires_at, revoked_at, revoked_by`
+
+func scanSupportGrant(row pgx.Row, g *domain.SupportGrant) error {
+	return row.Scan(&g.ID, &g.TenantID, &g.GrantedBy, &g.Reason, &g.ReadOnly,
+		&g.CreatedAt, &g.ExpiresAt, &g.RevokedAt, &g.RevokedBy)
+}
+
+// CreateSupportGrant inserts a support access grant.
+func (p *PostgresClient) CreateSupportGrant(ctx context.Context, g *domain.SupportGrant) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now().UTC()
+	}
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO support_access_grants (id, tenant_id, granted_by, reason, read_only, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, g.ID, g.TenantID, g.GrantedBy, g.Reason, g.ReadOnly, g.CreatedAt, g.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("postgres: create support grant: %w", err)
+	}
+	return nil
+}
+
+// ListSupportGrants returns the support access grants of a tenant, newest
+// first, including expired and revoked ones.
+func (p *PostgresClient) ListSupportGrants(ctx context.Context, tenantID uuid.UUID) ([]domain.SupportGrant, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+supportGrantColumns+`
+		FROM support_access_grants
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+	`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list support grants: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []domain.SupportGrant{}
+	for rows.Next() {
+		var g domain.SupportGrant
+		if err := scanSupportGrant(rows, &g); err != nil {
+			return nil, fmt.Errorf("postgres: scan support grant: %w", err)
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// GetActiveSupportGrant returns the unexpired, unrevoked support grant of a
+// tenant. When several are active the broadest wins: read-write before
+// read-only, then the one lasting longest.
+func (p *PostgresClient) GetActiveSupportGrant(ctx context.Context, tenantID uuid.UUID) (*domain.SupportGrant, error) {
+	var g domain.SupportGrant
+	err := scanSupportGrant(p.pool.QueryRow(ctx, `
+		SELECT`+supportGrantColumns+`
+		FROM support_access_grants
+		WHERE tenant_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY read_only ASC, expires_at DESC
+		LIMIT 1
+	`, tenantID), &g)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: active support grant not found: %s", tenantID)
+		}
+		return nil, fmt.Errorf("postgres: get active support grant: %w", err)
+	}
+	return &g, nil
+}
+
+// RevokeSupportGrant ends a support grant immediately. Grants already
+// revoked are reported as not found.
+func (p *PostgresClient) RevokeSupportGrant(ctx context.Context, tenantID, grantID uuid.UUID, revokedBy string) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE support_access_grants
+		SET revoked_at = NOW(), revoked_by = $1
+		WHERE id = $2 AND tenant_id = $3 AND revoked_at IS NULL
+	`, revokedBy, grantID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: revoke support grant: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: support grant not found: %s", grantID)
+	}
+	return nil
+}
+
+// RecordSupportAccess appends a request made under a support grant to the
+// tenant's support access audit.
+func (p *PostgresClient) RecordSupportAccess(ctx context.Context, ev *domain.SupportAccessEvent) error {
+	if ev.ID == uuid.Nil {
+		ev.ID = uuid.New()
+	}
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now().UTC()
+	}
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO support_access_events (id, tenant_id, grant_id, actor_user_id, actor_tenant_id,
+			method, path, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, ev.ID, ev.TenantID, ev.GrantID, ev.ActorUserID, ev.ActorTenantID,
+		ev.Method, ev.Path, ev.Status, ev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: record support access: %w", err)
+	}
+	return nil
+}
+
+// ListSupportAccessEvents returns the latest requests support made in a
+// tenant, newest first.
+func (p *PostgresClient) ListSupportAccessEvents(ctx context.Context, tenantID uuid.UUID, limit int) ([]domain.SupportAccessEvent, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tenant_id, grant_id, actor_user_id, actor_tenant_id, method, path, status, created_at
+		FROM support_access_events
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list support access events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.SupportAccessEvent{}
+	for rows.Next() {
+		var ev domain.SupportAccessEvent
+		if err := rows.Scan(&ev.ID, &ev.TenantID, &ev.GrantID, &ev.ActorUserID, &ev.ActorTenantID,
+			&ev.Method, &ev.Path, &ev.Status, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan support access event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// ListJobs returns all analysis jobs for a tenant, ordered by creation date descending.
+func (p *PostgresClient) ListJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error) {
+	return p.ListJobsFiltered(ctx, tenantID, domain.JobListFilter{})
+}
+
+// CountJobs returns how many analyses a tenant holds, those in the trash
+// included.
+func (p *PostgresClient) CountJobs(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	var n int
+	if err := p.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM analysis_jobs WHERE tenant_id = $1
+	`, tenantID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("postgres: count jobs: %w", err)
+	}
+	return n, nil
+}
+
+// ListJobsFiltered returns the analysis jobs of a tenant matching filter,
+// ordered by creation date descending. Analyses in the trash are left out.
+func (p *PostgresClient) ListJobsFiltered(ctx context.Context, tenantID uuid.UUID, filter domain.JobListFilter) ([]domain.AnalysisJob, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT`+jobColumns+`
+		FROM analysis_jobs
+		WHERE tenant_id = $1 AND deleted_at IS NULL
+			AND ($2 = '' OR investigation_status = $2)
+			AND ($3 = '' OR investigation_assignee = $3)
+		ORDER BY created_at DESC
+	`, tenantID, string(filter.InvestigationStatus), filter.Assignee)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list jobs: %w", err)
+	}
+	return collectJobs(rows)
+}
+
+func collectJobs(rows pgx.Rows) ([]domain.AnalysisJob, error) {
+	defer rows.Close()
+
+	var jobs []domain.AnalysisJob
+	for rows.Next() {
+		var j domain.AnalysisJob
+		if err := scanJob(rows, &j); err != nil {
+			return nil, fmt.Errorf("postgres: scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// SoftDeleteJob moves a job to the trash. Its data is kept until the job
+// is restored or purged.
+func (p *PostgresClient) SoftDeleteJob(ctx context.Context, tenantID, jobID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET deleted_at = $1, updated_at = $1
+		WHERE id = $2 AND tenant_id = $3 AND deleted_at IS NULL
+	`, time.Now().UTC(), jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: soft delete job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// RestoreJob takes a job out of the trash.
+func (p *PostgresClient) RestoreJob(ctx context.Context, tenantID, jobID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET deleted_at = NULL, updated_at = $1
+		WHERE id = $2 AND tenant_id = $3 AND deleted_at IS NOT NULL
+	`, time.Now().UTC(), jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: restore job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: deleted job not found: %s", jobID)
+	}
+	return nil
+}
+
+// ListDeletedJobs returns the jobs of a tenant in the trash, most recently
+// deleted first.
+func (p *PostgresClient) ListDeletedJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT`+jobColumns+`
+		FROM analysis_jobs
+		WHERE tenant_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list deleted jobs: %w", err)
+	}
+	return collectJobs(rows)
+}
+
+// ListPurgeableJobs returns up to limit jobs across all tenants that were
+// moved to the trash before the given time.
+func (p *PostgresClient) ListPurgeableJobs(ctx context.Context, before time.Time, limit int) ([]domain.AnalysisJob, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+jobColumns+`
+		FROM analysis_jobs
+		WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list purgeable jobs: %w", err)
+	}
+	return collectJobs(rows)
+}
+
+// queueRateSampleSize is how many recently completed jobs the processing
+// rate of GetJobQueueSnapshot is taken from.
+const queueRateSampleSize = 50
+
+// GetJobQueueSnapshot returns the queued and running jobs of all tenants
+// with the size of their files, and the milliseconds per MB that recently
+// completed jobs took from start to completion.
+func (p *PostgresClient) GetJobQueueSnapshot(ctx context.Context) (*domain.JobQueueSnapshot, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT j.id, j.tenant_id, j.status, j.priority, COALESCE(f.size_bytes, 0), j.jvm_heap_mb,
+		       j.created_at, j.started_at
+		FROM analysis_jobs j
+		LEFT JOIN log_files f ON f.id = j.file_id
+		WHERE j.status NOT IN ($1, $2, $3) AND j.deleted_at IS NULL
+		ORDER BY j.created_at
+	`, domain.JobStatusComplete, domain.JobStatusFailed, domain.JobStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: job queue snapshot: %w", err)
+	}
+	defer rows.Close()
+
+	var snap domain.JobQueueSnapshot
+	for rows.Next() {
+		var (
+			q      domain.QueuedJob
+			status domain.JobStatus
+		)
+		if err := rows.Scan(&q.ID, &q.TenantID, &status, &q.Priority, &q.SizeBytes, &q.JVMHeapMB, &q.CreatedAt, &q.StartedAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan job queue snapshot: %w", err)
+		}
+		if status == domain.JobStatusQueued {
+			q.StartedAt = nil
+			snap.Queued = append(snap.Queued, q)
+		} else {
+			snap.Running = append(snap.Running, q)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: job queue snapshot rows: %w", err)
+	}
+
+	var msPerMB *float64
+	err = p.pool.QueryRow(ctx, `
+		SELECT SUM(EXTRACT(EPOCH FROM (j.completed_at - j.started_at)) * 1000)
+		       / NULLIF(SUM(f.size_bytes) / 1048576.0, 0)
+		FROM (
+			SELECT file_id, started_at, completed_at
+			FROM analysis_jobs
+			WHERE status = $1 AND started_at IS NOT NULL AND completed_at IS NOT NULL
+			ORDER BY completed_at DESC
+			LIMIT $2
+		) j
+		JOIN log_files f ON f.id = j.file_id
+	`, domain.JobStatusComplete, queueRateSampleSize).Scan(&msPerMB)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: job processing rate: %w", err)
+	}
+	if msPerMB != nil {
+		snap.MSPerMB = *msPerMB
+	}
+	return &snap, nil
+}
+
+// GetJobCostModel returns the cost model of a tenant's files of a source
+// type.
+func (p *PostgresClient) GetJobCostModel(ctx context.Context, tenantID uuid.UUID, sourceType domain.LogSourceType) (*domain.JobCostModel, error) {
+	var m domain.JobCostModel
+	err := p.reader(ctx).QueryRow(ctx, `
+		SELECT tenant_id, source_type, jobs, rows_fit, jar_ms_fit, insert_ms_fit,
+			storage_bytes_per_row, error_pct, fitted_at
+		FROM job_cost_models
+		WHERE tenant_id = $1 AND source_type = $2
+	`, tenantID, sourceType).Scan(&m.TenantID, &m.SourceType, &m.Jobs, &m.Rows, &m.JARMS, &m.InsertMS,
+		&m.StorageBytesPerRow, &m.ErrorPct, &m.FittedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: job cost model not found: %s", sourceType)
+		}
+		return nil, fmt.Errorf("postgres: get job cost model: %w", err)
+	}
+	return &m, nil
+}
+
+// UpsertJobCostModel creates or replaces the cost model of a tenant's
+// files of a source type.
+func (p *PostgresClient) UpsertJobCostModel(ctx context.Context, m *domain.JobCostModel) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO job_cost_models (tenant_id, source_type, jobs, rows_fit, jar_ms_fit, insert_ms_fit,
+			storage_bytes_per_row, error_pct, fitted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tenant_id, source_type) DO UPDATE SET
+			jobs = EXCLUDED.jobs,
+			rows_fit = EXCLUDED.rows_fit,
+			jar_ms_fit = EXCLUDED.jar_ms_fit,
+			insert_ms_fit = EXCLUDED.insert_ms_fit,
+			storage_bytes_per_row = EXCLUDED.storage_bytes_per_row,
+			error_pct = EXCLUDED.error_pct,
+			fitted_at = EXCLUDED.fitted_at
+	`, m.TenantID, m.SourceType, m.Jobs, m.Rows, m.JARMS, m.InsertMS,
+		m.StorageBytesPerRow, m.ErrorPct, m.FittedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: upsert job cost model: %w", err)
+	}
+	return nil
+}
+
+// ListJobCostSamples returns, for every tenant and source type, the latest
+// perModel completed jobs that ran the JAR, along with any older job whose
+// estimate awaits its accuracy. Rows are those recorded as stored in usage,
+// else the JAR's counts.
+func (p *PostgresClient) ListJobCostSamples(ctx context.Context, perModel int) ([]domain.JobCostSample, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tenant_id, source_type, size_bytes, rows_stored, jar_ms, insert_ms, queue_ms, sampled,
+			estimate, estimate_accuracy
+		FROM (
+			SELECT j.id, j.tenant_id, f.source_type, f.size_bytes,
+				COALESCE(u.amount, COALESCE(j.api_count, 0) + COALESCE(j.sql_count, 0)
+					+ COALESCE(j.filter_count, 0) + COALESCE(j.esc_count, 0)) AS rows_stored,
+				j.wall_time_ms AS jar_ms,
+				GREATEST(EXTRACT(EPOCH FROM (j.completed_at - j.started_at)) * 1000 - j.wall_time_ms, 0)::BIGINT AS insert_ms,
+				GREATEST(EXTRACT(EPOCH FROM (j.started_at - j.created_at)) * 1000, 0)::BIGINT AS queue_ms,
+				j.sampling IS NOT NULL AS sampled, j.estimate, j.estimate_accuracy,
+				ROW_NUMBER() OVER (PARTITION BY j.tenant_id, f.source_type ORDER BY j.completed_at DESC) AS n
+			FROM analysis_jobs j
+			JOIN log_files f ON f.id = j.file_id
+			LEFT JOIN usage_events u ON u.tenant_id = j.tenant_id AND u.metric = $2 AND u.source_id = j.id
+			WHERE j.status = $1 AND j.deleted_at IS NULL AND f.size_bytes > 0
+				AND j.started_at IS NOT NULL AND j.completed_at IS NOT NULL AND j.wall_time_ms IS NOT NULL
+		) s
+		WHERE n <= $3 OR (estimate IS NOT NULL AND estimate_accuracy IS NULL)
+		ORDER BY tenant_id, source_type, n
+	`, domain.JobStatusComplete, domain.UsageRowsStored, perModel)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list job cost samples: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.JobCostSample
+	for rows.Next() {
+		var s domain.JobCostSample
+		if err := rows.Scan(&s.JobID, &s.TenantID, &s.SourceType, &s.SizeBytes, &s.Rows, &s.JARMS, &s.InsertMS,
+			&s.QueueMS, &s.Sampled, &s.Estimate, &s.Accuracy); err != nil {
+			return nil, fmt.Errorf("postgres: scan job cost sample: %w", err)
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: job cost sample rows: %w", err)
+	}
+	return out, nil
+}
+
+// UpdateJobEstimateAccuracy records how the estimate of a job compared
+// with its actual cost.
+func (p *PostgresClient) UpdateJobEstimateAccuracy(ctx context.Context, tenantID, jobID uuid.UUID, acc *domain.EstimateAccuracy) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET estimate_accuracy = $1
+		WHERE id = $2 AND tenant_id = $3
+	`, acc, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update job estimate accuracy: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+	return nil
+}
+
+// GetQueueActivity returns the queued and running jobs of all tenants with
+// their latest job event, the heartbeats of the workers seen since
+// workersSince, and the number of jobs that completed or failed since
+// finishedSince.
+func (p *PostgresClient) GetQueueActivity(ctx context.Context, finishedSince, workersSince time.Time) (*domain.QueueActivity, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT j.id, j.tenant_id, j.status, j.priority, j.progress_pct, j.created_at, j.started_at,
+		       e.stage, e.message, e.occurred_at
+		FROM analysis_jobs j
+		LEFT JOIN LATERAL (
+			SELECT stage, message, occurred_at
+			FROM job_events
+			WHERE job_id = j.id
+			ORDER BY occurred_at DESC, id DESC
+			LIMIT 1
+		) e ON true
+		WHERE j.status NOT IN ($1, $2, $3) AND j.deleted_at IS NULL
+		ORDER BY j.created_at
+	`, domain.JobStatusComplete, domain.JobStatusFailed, domain.JobStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: queue activity jobs: %w", err)
+	}
+	defer rows.Close()
+
+	activity := domain.QueueActivity{FinishedSince: finishedSince}
+	for rows.Next() {
+		var (
+			j          domain.ActiveJob
+			stage, msg *string
+			eventAt    *time.Time
+		)
+		if err := rows.Scan(&j.ID, &j.TenantID, &j.Status, &j.Priority, &j.ProgressPct, &j.CreatedAt, &j.StartedAt,
+			&stage, &msg, &eventAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan queue activity job: %w", err)
+		}
+		if eventAt != nil {
+			j.LastEvent = &domain.JobEvent{TenantID: j.TenantID, JobID: j.ID, OccurredAt: *eventAt}
+			if stage != nil {
+				j.LastEvent.Stage = *stage
+			}
+			if msg != nil {
+				j.LastEvent.Message = *msg
+			}
+		}
+		activity.Jobs = append(activity.Jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: queue activity jobs rows: %w", err)
+	}
+
+	// The count is joined with the workers so that both come in one round
+	// trip; without workers there is a single row with NULL worker columns.
+	rows, err = p.pool.Query(ctx, `
+		SELECT f.finished, w.worker_id, w.hostname, w.started_at, w.running_jobs, w.max_jobs, w.last_seen_at
+		FROM (SELECT COUNT(*) AS finished FROM analysis_jobs WHERE completed_at >= $1) f
+		LEFT JOIN worker_heartbeats w ON w.last_seen_at >= $2
+		ORDER BY w.worker_id
+	`, finishedSince, workersSince)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: queue activity workers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			workerID, hostname   *string
+			startedAt, lastSeen  *time.Time
+			runningJobs, maxJobs *int
+		)
+		if err := rows.Scan(&activity.FinishedCount, &workerID, &hostname, &startedAt, &runningJobs, &maxJobs, &lastSeen); err != nil {
+			return nil, fmt.Errorf("postgres: scan queue activity worker: %w", err)
+		}
+		if workerID == nil {
+			continue
+		}
+		activity.Workers = append(activity.Workers, domain.WorkerHeartbeat{
+			WorkerID:    *workerID,
+			Hostname:    *hostname,
+			StartedAt:   *startedAt,
+			RunningJobs: *runningJobs,
+			MaxJobs:     *maxJobs,
+			LastSeenAt:  *lastSeen,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: queue activity workers rows: %w", err)
+	}
+	return &activity, nil
+}
+
+// RecordWorkerHeartbeat records that a worker is alive, with the jobs it
+// is running.
+func (p *PostgresClient) RecordWorkerHeartbeat(ctx context.Context, hb *domain.WorkerHeartbeat) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO worker_heartbeats (worker_id, hostname, started_at, running_jobs, max_jobs, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (worker_id) DO UPDATE
+		SET hostname = EXCLUDED.hostname, started_at = EXCLUDED.started_at,
+		    running_jobs = EXCLUDED.running_jobs, max_jobs = EXCLUDED.max_jobs, last_seen_at = NOW()
+	`, hb.WorkerID, hb.Hostname, hb.StartedAt, hb.RunningJobs, hb.MaxJobs)
+	if err != nil {
+		return fmt.Errorf("postgres: record worker heartbeat: %w", err)
+	}
+	return nil
+}
+
+// DeleteWorkerHeartbeat removes the heartbeat of a worker shutting down.
+func (p *PostgresClient) DeleteWorkerHeartbeat(ctx context.Context, workerID string) error {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM worker_heartbeats WHERE worker_id = $1`, workerID); err != nil {
+		return fmt.Errorf("postgres: delete worker heartbeat: %w", err)
+	}
+	return nil
+}
+
+// PurgeJob permanently deletes a job, live or in the trash, with its
+// exports, conversations, violations, event log and investigation
+// history. AI interactions and search history keep their rows without the
+// job. The uploaded file is deleted with the last job using it. The S3
+// objects left behind are returned for the caller to delete.
+func (p *PostgresClient) PurgeJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.PurgedJob, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: purge job begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var purged domain.PurgedJob
+	rows, err := tx.Query(ctx, `
+		SELECT s3_key FROM search_exports
+		WHERE job_id = $1 AND tenant_id = $2 AND s3_key IS NOT NULL AND s3_key <> ''
+	`, jobID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: purge job exports: %w", err)
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("postgres: purge job exports scan: %w", err)
+		}
+		purged.ExportS3Keys = append(purged.ExportS3Keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: purge job exports: %w", err)
+	}
+
+	for _, table := range []string{"ai_interactions", "search_history"} {
+		if _, err := tx.Exec(ctx, `UPDATE `+table+` SET job_id = NULL WHERE job_id = $1 AND tenant_id = $2`, jobID, tenantID); err != nil {
+			return nil, fmt.Errorf("postgres: purge job detach %s: %w", table, err)
+		}
+	}
+
+	var fileID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		DELETE FROM analysis_jobs
+		WHERE id = $1 AND tenant_id = $2
+		RETURNING file_id
+	`, jobID, tenantID).Scan(&fileID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: job not found: %s", jobID)
+		}
+		return nil, fmt.Errorf("postgres: purge job: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		DELETE FROM log_files
+		WHERE id = $1 AND tenant_id = $2
+			AND NOT EXISTS (SELECT 1 FROM analysis_jobs WHERE file_id = $1)
+		RETURNING s3_key
+	`, fileID, tenantID).Scan(&purged.FileS3Key)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("postgres: purge job file: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("postgres: purge job commit: %w", err)
+	}
+	return &purged, nil
+}
+
+// --------------------------------------------------------------------------
+// AI Interactions
+// --------------------------------------------------------------------------
+
+// CreateAIInteraction inserts a new AI interaction record.
+func (p *PostgresClient) CreateAIInteraction(ctx context.Context, ai *domain.AIInteraction) error {
+	if ai.ID == uuid.Nil {
+		ai.ID = uuid.New()
+	}
+	ai.CreatedAt = time.Now().UTC()
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO ai_interactions (
+			id, tenant_id, job_id, user_id, skill_name,
+			input_text, output_text, referenced_lines,
+			tokens_used, latency_ms, status, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, ai.ID, ai.TenantID, ai.JobID, ai.UserID, ai.SkillName,
+		ai.InputText, ai.OutputText, ai.ReferencedLines,
+		ai.TokensUsed, ai.LatencyMS, ai.Status, ai.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: create ai interaction: %w", err)
+	}
+	return nil
+}
+
+// UpdateAIInteraction updates the output fields of an AI interaction after
+// the AI skill completes.
+func (p *PostgresClient) UpdateAIInteraction(ctx context.Context, tenantID, interactionID uuid.UUID, outputText *string, tokensUsed *int, latencyMS *int, status string) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE ai_interactions
+		SET output_text = $1, tokens_used = $2, latency_ms = $3, status = $4
+		WHERE id = $5 AND tenant_id = $6
+	`, outputText, tokensUsed, latencyMS, status, interactionID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update ai interaction: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: ai interaction not found: %s", interactionID)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Saved Searches
+// --------------------------------------------------------------------------
+
+// CreateSavedSearch inserts a new saved search.
+func (p *PostgresClient) CreateSavedSearch(ctx context.Context, s *domain.SavedSearch) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	s.CreatedAt = time.Now().UTC()
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO saved_searches (id, tenant_id, user_id, name, kql_query, filters, is_pinned, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, s.ID, s.TenantID, s.UserID, s.Name, s.KQLQuery, s.Filters, s.IsPinned, s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: create saved search: %w", err)
+	}
+	return nil
+}
+
+// ListSavedSearches returns all saved searches for a tenant and user.
+func (p *PostgresClient) ListSavedSearches(ctx context.Context, tenantID uuid.UUID, userID string) ([]domain.SavedSearch, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tenant_id, user_id, name, kql_query, filters, is_pinned, created_at
+		FROM saved_searches
+		WHERE tenant_id = $1 AND user_id = $2
+		ORDER BY is_pinned DESC, created_at DESC
+	`, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []domain.SavedSearch
+	for rows.Next() {
+		var s domain.SavedSearch
+		if err := rows.Scan(
+			&s.ID, &s.TenantID, &s.UserID, &s.Name, &s.KQLQuery,
+			&s.Filters, &s.IsPinned, &s.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("postgres: scan saved search: %w", err)
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+// ListTenantSavedSearches returns the saved searches of every user of a
+// tenant, ordered by user and name.
+func (p *PostgresClient) ListTenantSavedSearches(ctx context.Context, tenantID uuid.UUID) ([]domain.SavedSearch, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tenant_id, user_id, name, kql_query, filters, is_pinned, created_at
+		FROM saved_searches
+		WHERE tenant_id = $1
+		ORDER BY user_id, name, created_at
+	`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list tenant saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	var searches []domain.SavedSearch
+	for rows.Next() {
+		var s domain.SavedSearch
+		if err := rows.Scan(
+			&s.ID, &s.TenantID, &s.UserID, &s.Name, &s.KQLQuery,
+			&s.Filters, &s.IsPinned, &s.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("postgres: scan saved search: %w", err)
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+// DeleteSavedSearch removes a saved search by ID, scoped to the tenant and user.
+func (p *PostgresClient) DeleteSavedSearch(ctx context.Context, tenantID uuid.UUID, userID string, searchID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM saved_searches
+		WHERE id = $1 AND tenant_id = $2 AND user_id = $3
+	`, searchID, tenantID, userID)
+	if err != nil {
+		return fmt.Errorf("postgres: delete saved search: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: saved search not found: %s", searchID)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Search Exports
+// --------------------------------------------------------------------------
+
+// Tenant bundles have no job_id; it reads as uuid.Nil.
+const searchExportColumns = `
+	id, tenant_id, COALESCE(job_id, '00000000-0000-0000-0000-000000000000'), user_id, status, format, query,
+	progress_pct, row_count, size_bytes, s3_key, error_message,
+	expires_at, created_at, updated_at, completed_at, provenance, regenerated_from`
+
+func scanSearchExport(row pgx.Row, e *domain.SearchExport) error {
+	if err := row.Scan(
+		&e.ID, &e.TenantID, &e.JobID, &e.UserID, &e.Status, &e.Format, &e.Query,
+		&e.ProgressPct, &e.RowCount, &e.SizeBytes, &e.S3Key, &e.ErrorMessage,
+		&e.ExpiresAt, &e.CreatedAt, &e.UpdatedAt, &e.CompletedAt, &e.Provenance, &e.RegeneratedFrom,
+	); err != nil {
+		return err
+	}
+	e.ProvenanceSummary = e.Provenance.Summary()
+	return nil
+}
+
+// CreateSearchExport inserts a new search export record. A JobID of
+// uuid.Nil is stored as NULL.
+func (p *PostgresClient) CreateSearchExport(ctx context.Context, e *domain.SearchExport) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	e.CreatedAt = now
+	e.UpdatedAt = now
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO search_exports (id, tenant_id, job_id, user_id, status, format, query, expires_at, created_at, updated_at, regenerated_from)
+		VALUES ($1, $2, NULLIF($3, '00000000-0000-0000-0000-000000000000'::uuid), $4, $5, $6, $7, $8, $9, $10, $11)
+	`, e.ID, e.TenantID, e.JobID, e.UserID, e.Status, e.Format, e.Query, e.ExpiresAt, e.CreatedAt, e.UpdatedAt, e.RegeneratedFrom)
+	if err != nil {
+		return fmt.Errorf("postgres: create search export: %w", err)
+	}
+	return nil
+}
+
+// GetSearchExport retrieves a search export by its ID within a tenant.
+func (p *PostgresClient) GetSearchExport(ctx context.Context, tenantID, exportID uuid.UUID) (*domain.SearchExport, error) {
+	var e domain.SearchExport
+	err := scanSearchExport(p.pool.QueryRow(ctx, `
+		SELECT`+searchExportColumns+`
+		FROM search_exports
+		WHERE id = $1 AND tenant_id = $2`+searchExportLiveJob+`
+	`, exportID, tenantID), &e)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: search export not found: %s", exportID)
+		}
+		return nil, fmt.Errorf("postgres: get search export: %w", err)
+	}
+	return &e, nil
+}
+
+// searchExportLiveJob hides the exports of analyses in the trash.
+const searchExportLiveJob = `
+		AND NOT EXISTS (SELECT 1 FROM analysis_jobs j
+			WHERE j.id = search_exports.job_id AND j.deleted_at IS NOT NULL)`
+
+// ListSearchExports returns the most recent search exports for a tenant.
+func (p *PostgresClient) ListSearchExports(ctx context.Context, tenantID uuid.UUID, limit int) ([]domain.SearchExport, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+searchExportColumns+`
+		FROM search_exports
+		WHERE tenant_id = $1`+searchExportLiveJob+`
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list search exports: %w", err)
+	}
+	defer rows.Close()
+
+	var exports []domain.SearchExport
+	for rows.Next() {
+		var e domain.SearchExport
+		if err := scanSearchExport(rows, &e); err != nil {
+			return nil, fmt.Errorf("postgres: scan search export: %w", err)
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// ListExpiredSearchExports returns up to limit exports across all tenants
+// whose expiry is before the given time and that have not been expired yet.
+func (p *PostgresClient) ListExpiredSearchExports(ctx context.Context, before time.Time, limit int) ([]domain.SearchExport, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+searchExportColumns+`
+		FROM search_exports
+		WHERE expires_at < $1 AND status <> 'expired'
+		ORDER BY expires_at
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list expired search exports: %w", err)
+	}
+	defer rows.Close()
+
+	var exports []domain.SearchExport
+	for rows.Next() {
+		var e domain.SearchExport
+		if err := scanSearchExport(rows, &e); err != nil {
+			return nil, fmt.Errorf("postgres: scan search export: %w", err)
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// UpdateSearchExportStatus transitions an export to a new status. If the new
+// status is "complete" or "failed", CompletedAt is also set.
+func (p *PostgresClient) UpdateSearchExportStatus(ctx context.Context, tenantID, exportID uuid.UUID, status domain.ExportStatus, errMsg *string) error {
+	now := time.Now().UTC()
+	var completedAt *time.Time
+	if status == domain.ExportStatusComplete || status == domain.ExportStatusFailed {
+		completedAt = &now
+	}
+
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE search_exports
+		SET status = $1, error_message = $2, updated_at = $3, completed_at = COALESCE($4, completed_at)
+		WHERE id = $5 AND tenant_id = $6
+	`, status, errMsg, now, completedAt, exportID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update search export status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: search export not found: %s", exportID)
+	}
+	return nil
+}
+
+// UpdateSearchExportProgress updates the progress percentage and the number
+// of rows written so far.
+func (p *PostgresClient) UpdateSearchExportProgress(ctx context.Context, tenantID, exportID uuid.UUID, progressPct int, rowCount int64) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE search_exports
+		SET progress_pct = $1, row_count = $2, updated_at = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, progressPct, rowCount, now, exportID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update search export progress: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: search export not found: %s", exportID)
+	}
+	return nil
+}
+
+// CompleteSearchExport records the uploaded object and its provenance and
+// marks the export complete.
+func (p *PostgresClient) CompleteSearchExport(ctx context.Context, tenantID, exportID uuid.UUID, s3Key string, rowCount, sizeBytes int64, prov *domain.ArtifactProvenance) error {
+	now := time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE search_exports
+		SET status = $1, progress_pct = 100, s3_key = $2, row_count = $3, size_bytes = $4,
+		    error_message = NULL, updated_at = $5, completed_at = $5, provenance = $6
+		WHERE id = $7 AND tenant_id = $8
+	`, domain.ExportStatusComplete, s3Key, rowCount, sizeBytes, now, prov, exportID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: complete search export: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: search export not found: %s", exportID)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Threshold Rules
+// --------------------------------------------------------------------------
+
+const thresholdRuleColumns = `
+	id, tenant_id, name, scope, scope_value, metric, comparator,
+	value, severity, enabled, created_at, updated_at`
+
+func scanThresholdRule(row pgx.Row, r *domain.ThresholdRule) error {
+	return row.Scan(
+		&r.ID, &r.TenantID, &r.Name, &r.Scope, &r.ScopeValue, &r.Metric, &r.Comparator,
+		&r.Value, &r.Severity, &r.Enabled, &r.CreatedAt, &r.UpdatedAt,
+	)
+}
+
+// CreateThresholdRule inserts a new threshold rule.
+func (p *PostgresClient) CreateThresholdRule(ctx context.Context, r *domain.ThresholdRule) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	r.CreatedAt = now
+	r.UpdatedAt = now
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO threshold_rules (id, tenant_id, name, scope, scope_value, metric, comparator, value, severity, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, r.ID, r.TenantID, r.Name, r.Scope, r.ScopeValue, r.Metric, r.Comparator, r.Value, r.Severity, r.Enabled, r.CreatedAt, r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: create threshold rule: %w", err)
+	}
+	return nil
+}
+
+// GetThresholdRule retrieves a threshold rule by its ID within a tenant.
+func (p *PostgresClient) GetThresholdRule(ctx context.Context, tenantID, ruleID uuid.UUID) (*domain.ThresholdRule, error) {
+	var r domain.ThresholdRule
+	err := scanThresholdRule(p.pool.QueryRow(ctx, `
+		SELECT`+thresholdRuleColumns+`
+		FROM threshold_rules
+		WHERE id = $1 AND tenant_id = $2
+	`, ruleID, tenantID), &r)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: threshold rule not found: %s", ruleID)
+		}
+		return nil, fmt.Errorf("postgres: get threshold rule: %w", err)
+	}
+	return &r, nil
+}
+
+// ListThresholdRules returns all threshold rules of a tenant, oldest first.
+func (p *PostgresClient) ListThresholdRules(ctx context.Context, tenantID uuid.UUID) ([]domain.ThresholdRule, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+thresholdRuleColumns+`
+		FROM threshold_rules
+		WHERE tenant_id = $1
+		ORDER BY created_at ASC
+	`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list threshold rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []domain.ThresholdRule
+	for rows.Next() {
+		var r domain.ThresholdRule
+		if err := scanThresholdRule(rows, &r); err != nil {
+			return nil, fmt.Errorf("postgres: scan threshold rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateThresholdRule overwrites the editable fields of a threshold rule.
+func (p *PostgresClient) UpdateThresholdRule(ctx context.Context, r *domain.ThresholdRule) error {
+	r.UpdatedAt = time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE threshold_rules
+		SET name = $1, scope = $2, scope_value = $3, metric = $4, comparator = $5,
+		    value = $6, severity = $7, enabled = $8, updated_at = $9
+		WHERE id = $10 AND tenant_id = $11
+	`, r.Name, r.Scope, r.ScopeValue, r.Metric, r.Comparator, r.Value, r.Severity, r.Enabled, r.UpdatedAt, r.ID, r.TenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update threshold rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: threshold rule not found: %s", r.ID)
+	}
+	return nil
+}
+
+// DeleteThresholdRule removes a threshold rule. Violations already recorded
+// against it are kept.
+func (p *PostgresClient) DeleteThresholdRule(ctx context.Context, tenantID, ruleID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM threshold_rules
+		WHERE id = $1 AND tenant_id = $2
+	`, ruleID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: delete threshold rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: threshold rule not found: %s", ruleID)
+	}
+	return nil
+}
+
+// ReplaceJobViolations stores the violations of a job, replacing any recorded
+// by an earlier run, and updates the job's violation count.
+func (p *PostgresClient) ReplaceJobViolations(ctx context.Context, tenantID, jobID uuid.UUID, violations []domain.ThresholdViolation) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: replace job violations begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM threshold_violations
+		WHERE tenant_id = $1 AND job_id = $2
+	`, tenantID, jobID); err != nil {
+		return fmt.Errorf("postgres: replace job violations delete: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for i := range violations {
+		v := &violations[i]
+		if v.ID == uuid.Nil {
+			v.ID = uuid.New()
+		}
+		v.TenantID, v.JobID, v.CreatedAt = tenantID, jobID, now
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO threshold_violations (id, tenant_id, job_id, rule_id, rule_name, scope, scope_value,
+				metric, comparator, threshold, observed, severity, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		`, v.ID, v.TenantID, v.JobID, v.RuleID, v.RuleName, v.Scope, v.ScopeValue,
+			v.Metric, v.Comparator, v.Threshold, v.Observed, v.Severity, v.CreatedAt); err != nil {
+			return fmt.Errorf("postgres: replace job violations insert: %w", err)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET violation_count = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, len(violations), now, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: replace job violations count: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job not found: %s", jobID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: replace job violations commit: %w", err)
+	}
+	return nil
+}
+
+// ListJobViolations returns the violations recorded for a job, most severe first.
+func (p *PostgresClient) ListJobViolations(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.ThresholdViolation, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT id, tenant_id, job_id, rule_id, rule_name, scope, scope_value,
+			metric, comparator, threshold, observed, severity, created_at
+		FROM threshold_violations
+		WHERE tenant_id = $1 AND job_id = $2
+		ORDER BY CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, rule_name
+	`, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list job violations: %w", err)
+	}
+	defer rows.Close()
+
+	var violations []domain.ThresholdViolation
+	for rows.Next() {
+		var v domain.ThresholdViolation
+		if err := rows.Scan(
+			&v.ID, &v.TenantID, &v.JobID, &v.RuleID, &v.RuleName, &v.Scope, &v.ScopeValue,
+			&v.Metric, &v.Comparator, &v.Threshold, &v.Observed, &v.Severity, &v.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("postgres: scan job violation: %w", err)
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+// --------------------------------------------------------------------------
+// Ingestion Filter Rules
+// --------------------------------------------------------------------------
+
+const ingestionFilterRuleColumns = `
+	id, tenant_id, name, field, operator, value, case_sensitive,
+	action, enabled, created_at, updated_at`
+
+func scanIngestionFilterRule(row pgx.Row, r *domain.IngestionFilterRule) error {
+	return row.Scan(
+		&r.ID, &r.TenantID, &r.Name, &r.Field, &r.Operator, &r.Value, &r.CaseSensitive,
+		&r.Action, &r.Enabled, &r.CreatedAt, &r.UpdatedAt,
+	)
+}
+
+// CreateIngestionFilterRule inserts a new ingestion filter rule.
+func (p *PostgresClient) CreateIngestionFilterRule(ctx context.Context, r *domain.IngestionFilterRule) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	r.CreatedAt = now
+	r.UpdatedAt = now
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO ingestion_filter_rules (id, tenant_id, name, field, operator, value, case_sensitive, action, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, r.ID, r.TenantID, r.Name, r.Field, r.Operator, r.Value, r.CaseSensitive, r.Action, r.Enabled, r.CreatedAt, r.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: create ingestion filter rule: %w", err)
+	}
+	return nil
+}
+
+// GetIngestionFilterRule retrieves an ingestion filter rule by its ID within
+// a tenant.
+func (p *PostgresClient) GetIngestionFilterRule(ctx context.Context, tenantID, ruleID uuid.UUID) (*domain.IngestionFilterRule, error) {
+	var r domain.IngestionFilterRule
+	err := scanIngestionFilterRule(p.pool.QueryRow(ctx, `
+		SELECT`+ingestionFilterRuleColumns+`
+		FROM ingestion_filter_rules
+		WHERE id = $1 AND tenant_id = $2
+	`, ruleID, tenantID), &r)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: ingestion filter rule not found: %s", ruleID)
+		}
+		return nil, fmt.Errorf("postgres: get ingestion filter rule: %w", err)
+	}
+	return &r, nil
+}
+
+// ListIngestionFilterRules returns all ingestion filter rules of a tenant,
+// oldest first. The first rule an entry matches decides its fate, so the
+// order matters.
+func (p *PostgresClient) ListIngestionFilterRules(ctx context.Context, tenantID uuid.UUID) ([]domain.IngestionFilterRule, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+ingestionFilterRuleColumns+`
+		FROM ingestion_filter_rules
+		WHERE tenant_id = $1
+		ORDER BY created_at ASC
+	`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list ingestion filter rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []domain.IngestionFilterRule
+	for rows.Next() {
+		var r domain.IngestionFilterRule
+		if err := scanIngestionFilterRule(rows, &r); err != nil {
+			return nil, fmt.Errorf("postgres: scan ingestion filter rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateIngestionFilterRule overwrites the editable fields of an ingestion
+// filter rule.
+func (p *PostgresClient) UpdateIngestionFilterRule(ctx context.Context, r *domain.IngestionFilterRule) error {
+	r.UpdatedAt = time.Now().UTC()
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE ingestion_filter_rules
+		SET name = $1, field = $2, operator = $3, value = $4, case_sensitive = $5,
+		    action = $6, enabled = $7, updated_at = $8
+		WHERE id = $9 AND tenant_id = $10
+	`, r.Name, r.Field, r.Operator, r.Value, r.CaseSensitive, r.Action, r.Enabled, r.UpdatedAt, r.ID, r.TenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update ingestion filter rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: ingestion filter rule not found: %s", r.ID)
+	}
+	return nil
+}
+
+// DeleteIngestionFilterRule removes an ingestion filter rule. The counts
+// already recorded against it on jobs are kept.
+func (p *PostgresClient) DeleteIngestionFilterRule(ctx context.Context, tenantID, ruleID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM ingestion_filter_rules
+		WHERE id = $1 AND tenant_id = $2
+	`, ruleID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: delete ingestion filter rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: ingestion filter rule not found: %s", ruleID)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Analysis Links and Ticketing Integrations
+// --------------------------------------------------------------------------
+
+const analysisLinkColumns = `
+	id, tenant_id, job_id, type, external_key, title, status, summary, status_synced_at, created_by, created_at`
+
+func scanAnalysisLink(row pgx.Row, l *domain.AnalysisLink) error {
+	return row.Scan(
+		&l.ID, &l.TenantID, &l.JobID, &l.Type, &l.ExternalKey, &l.Title, &l.Status, &l.Summary,
+		&l.StatusSyncedAt, &l.CreatedBy, &l.CreatedAt,
+	)
+}
+
+func collectAnalysisLinks(rows pgx.Rows) ([]domain.AnalysisLink, error) {
+	defer rows.Close()
+	var links []domain.AnalysisLink
+	for rows.Next() {
+		var l domain.AnalysisLink
+		if err := scanAnalysisLink(rows, &l); err != nil {
+			return nil, fmt.Errorf("postgres: scan analysis link: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// CreateAnalysisLink links an analysis to an external record.
+// ErrAlreadyExists is returned when the analysis is linked to it already.
+func (p *PostgresClient) CreateAnalysisLink(ctx context.Context, l *domain.AnalysisLink) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	l.CreatedAt = time.Now().UTC()
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO analysis_links (id, tenant_id, job_id, type, external_key, title, status, summary, status_synced_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, l.ID, l.TenantID, l.JobID, l.Type, l.ExternalKey, l.Title, l.Status, l.Summary, l.StatusSyncedAt, l.CreatedBy, l.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("postgres: create analysis link: %w", err)
+	}
+	return nil
+}
+
+// ListAnalysisLinks returns the links of an analysis, oldest first.
+func (p *PostgresClient) ListAnalysisLinks(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.AnalysisLink, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT`+analysisLinkColumns+`
+		FROM analysis_links
+		WHERE tenant_id = $1 AND job_id = $2
+		ORDER BY created_at ASC
+	`, tenantID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list analysis links: %w", err)
+	}
+	return collectAnalysisLinks(rows)
+}
+
+// ListAnalysisLinksByJobs returns the links of several analyses of a
+// tenant, by analysis.
+func (p *PostgresClient) ListAnalysisLinksByJobs(ctx context.Context, tenantID uuid.UUID, jobIDs []uuid.UUID) (map[uuid.UUID][]domain.AnalysisLink, error) {
+	out := make(map[uuid.UUID][]domain.AnalysisLink)
+	if len(jobIDs) == 0 {
+		return out, nil
+	}
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT`+analysisLinkColumns+`
+		FROM analysis_links
+		WHERE tenant_id = $1 AND job_id = ANY($2)
+		ORDER BY created_at ASC
+	`, tenantID, jobIDs)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list analysis links by jobs: %w", err)
+	}
+	links, err := collectAnalysisLinks(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		out[l.JobID] = append(out[l.JobID], l)
+	}
+	return out, nil
+}
+
+// ListAnalysisLinksToSync returns the Jira and ServiceNow links of every
+// tenant whose status was last synced before staleBefore, never synced
+// first, at most limit.
+func (p *PostgresClient) ListAnalysisLinksToSync(ctx context.Context, staleBefore time.Time, limit int) ([]domain.AnalysisLink, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+analysisLinkColumns+`
+		FROM analysis_links
+		WHERE type <> 'url' AND (status_synced_at IS NULL OR status_synced_at < $1)
+		ORDER BY status_synced_at NULLS FIRST
+		LIMIT $2
+	`, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list analysis links to sync: %w", err)
+	}
+	return collectAnalysisLinks(rows)
+}
+
+// UpdateAnalysisLinkStatus records the status and summary synced for a
+// link at syncedAt.
+func (p *PostgresClient) UpdateAnalysisLinkStatus(ctx context.Context, tenantID, linkID uuid.UUID, status, summary string, syncedAt time.Time) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE analysis_links
+		SET status = $1, summary = $2, status_synced_at = $3
+		WHERE id = $4 AND tenant_id = $5
+	`, status, summary, syncedAt, linkID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update analysis link status: %w", err)
+	}
+	return nil
+}
+
+// DeleteAnalysisLink removes a link of an analysis.
+func (p *PostgresClient) DeleteAnalysisLink(ctx context.Context, tenantID, jobID, linkID uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM analysis_links
+		WHERE id = $1 AND job_id = $2 AND tenant_id = $3
+	`, linkID, jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: delete analysis link: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: analysis link not found: %s", linkID)
+	}
+	return nil
+}
+
+// CreateIncidentGroup creates an incident group of the given analyses,
+// moving them from any group they were in.
+func (p *PostgresClient) CreateIncidentGroup(ctx context.Context, g *domain.IncidentGroup, jobIDs []uuid.UUID) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	g.CreatedAt = time.Now().UTC()
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: create incident group begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO incident_groups (id, tenant_id, name, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, g.ID, g.TenantID, g.Name, g.CreatedBy, g.CreatedAt); err != nil {
+		return fmt.Errorf("postgres: create incident group: %w", err)
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET incident_group_id = $1, updated_at = $2
+		WHERE tenant_id = $3 AND id = ANY($4) AND deleted_at IS NULL
+	`, g.ID, g.CreatedAt, g.TenantID, jobIDs)
+	if err != nil {
+		return fmt.Errorf("postgres: create incident group members: %w", err)
+	}
+	if int(tag.RowsAffected()) != len(jobIDs) {
+		return fmt.Errorf("postgres: job not found in incident group %s", g.ID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: create incident group commit: %w", err)
+	}
+	return nil
+}
+
+// GetIncidentGroup retrieves an incident group with its analyses, oldest
+// first. Analyses in the trash are left out.
+func (p *PostgresClient) GetIncidentGroup(ctx context.Context, tenantID, groupID uuid.UUID) (*domain.IncidentGroup, error) {
+	g := domain.IncidentGroup{Members: []domain.IncidentGroupMember{}}
+	err := p.reader(ctx).QueryRow(ctx, `
+		SELECT id, tenant_id, name, created_by, created_at
+		FROM incident_groups
+		WHERE id = $1 AND tenant_id = $2
+	`, groupID, tenantID).Scan(&g.ID, &g.TenantID, &g.Name, &g.CreatedBy, &g.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: incident group not found: %s", groupID)
+		}
+		return nil, fmt.Errorf("postgres: get incident group: %w", err)
+	}
+
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT j.id, f.filename, f.source_type, j.status, j.log_start, j.log_end
+		FROM analysis_jobs j
+		JOIN log_files f ON f.id = j.file_id
+		WHERE j.tenant_id = $1 AND j.incident_group_id = $2 AND j.deleted_at IS NULL
+		ORDER BY j.created_at ASC
+	`, tenantID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list incident group members: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m domain.IncidentGroupMember
+		if err := rows.Scan(&m.JobID, &m.Filename, &m.SourceType, &m.Status, &m.LogStart, &m.LogEnd); err != nil {
+			return nil, fmt.Errorf("postgres: scan incident group member: %w", err)
+		}
+		g.Members = append(g.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: list incident group members: %w", err)
+	}
+	return &g, nil
+}
+
+// SetJobIncidentGroup moves an analysis to an incident group of its
+// tenant, or out of its group when groupID is nil.
+func (p *PostgresClient) SetJobIncidentGroup(ctx context.Context, tenantID, jobID uuid.UUID, groupID *uuid.UUID) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET incident_group_id = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4 AND deleted_at IS NULL
+		  AND ($1::UUID IS NULL OR EXISTS (
+		      SELECT 1 FROM incident_groups g WHERE g.id = $1 AND g.tenant_id = $4))
+	`, groupID, time.Now().UTC(), jobID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: set job incident group: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: job or incident group not found: %s", jobID)
+	}
+	return nil
+}
+
+const ticketingIntegrationColumns = `
+	tenant_id, type, base_url, username, token_sealed, enabled, created_at, updated_at`
+
+func scanTicketingIntegration(row pgx.Row, ti *domain.TicketingIntegration) error {
+	return row.Scan(&ti.TenantID, &ti.Type, &ti.BaseURL, &ti.Username, &ti.Sealed, &ti.Enabled, &ti.CreatedAt, &ti.UpdatedAt)
+}
+
+// GetTicketingIntegration retrieves the integration of a tenant with one
+// ticketing system.
+func (p *PostgresClient) GetTicketingIntegration(ctx context.Context, tenantID uuid.UUID, t domain.AnalysisLinkType) (*domain.TicketingIntegration, error) {
+	var ti domain.TicketingIntegration
+	err := scanTicketingIntegration(p.pool.QueryRow(ctx, `
+		SELECT`+ticketingIntegrationColumns+`
+		FROM ticketing_integrations
+		WHERE tenant_id = $1 AND type = $2
+	`, tenantID, t), &ti)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: ticketing integration not found: %s", t)
+		}
+		return nil, fmt.Errorf("postgres: get ticketing integration: %w", err)
+	}
+	return &ti, nil
+}
+
+// UpsertTicketingIntegration creates or replaces the integration of a
+// tenant with one ticketing system.
+func (p *PostgresClient) UpsertTicketingIntegration(ctx context.Context, ti *domain.TicketingIntegration) error {
+	now := time.Now().UTC()
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO ticketing_integrations (tenant_id, type, base_url, username, token_sealed, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (tenant_id, type) DO UPDATE
+		SET base_url = EXCLUDED.base_url, username = EXCLUDED.username, token_sealed = EXCLUDED.token_sealed,
+		    enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at
+		RETURNING created_at, updated_at
+	`, ti.TenantID, ti.Type, ti.BaseURL, ti.Username, ti.Sealed, ti.Enabled, now).Scan(&ti.CreatedAt, &ti.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: upsert ticketing integration: %w", err)
+	}
+	return nil
+}
+
+// DeleteTicketingIntegration removes the integration of a tenant with one
+// ticketing system. Its links are kept with the status last synced.
+func (p *PostgresClient) DeleteTicketingIntegration(ctx context.Context, tenantID uuid.UUID, t domain.AnalysisLinkType) error {
+	tag, err := p.pool.Exec(ctx, `
+		DELETE FROM ticketing_integrations
+		WHERE tenant_id = $1 AND type = $2
+	`, tenantID, t)
+	if err != nil {
+		return fmt.Errorf("postgres: delete ticketing integration: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: ticketing integration not found: %s", t)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Local Users
+// --------------------------------------------------------------------------
+
+// CreateLocalUser inserts a user of the local auth provider.
+// ErrAlreadyExists is returned when the email is taken.
+func (p *PostgresClient) CreateLocalUser(ctx context.Context, u *domain.LocalUser) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	u.CreatedAt = time.Now().UTC()
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO local_users (id, tenant_id, email, password_hash, role, disabled, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, u.ID, u.TenantID, u.Email, u.PasswordHash, u.Role, u.Disabled, u.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("postgres: create local user: %w", err)
+	}
+	return nil
+}
+
+// GetLocalUserByEmail fetches a user of the local auth provider by email,
+// ignoring case.
+func (p *PostgresClient) GetLocalUserByEmail(ctx context.Context, email string) (*domain.LocalUser, error) {
+	var u domain.LocalUser
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, tenant_id, email, password_hash, role, disabled, created_at, last_login_at
+		FROM local_users
+		WHERE lower(email) = lower($1)
+	`, email).Scan(&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.Disabled, &u.CreatedAt, &u.LastLoginAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: local user not found: %s", email)
+		}
+		return nil, fmt.Errorf("postgres: get local user: %w", err)
+	}
+	return &u, nil
+}
+
+// CountLocalUsers returns the number of users of the local auth provider.
+func (p *PostgresClient) CountLocalUsers(ctx context.Context) (int, error) {
+	var n int
+	if err := p.pool.QueryRow(ctx, `SELECT count(*) FROM local_users`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("postgres: count local users: %w", err)
+	}
+	return n, nil
+}
+
+// RecordLocalLogin stamps the time a local user last signed in.
+func (p *PostgresClient) RecordLocalLogin(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	_, err := p.pool.Exec(ctx, `UPDATE local_users SET last_login_at = $1 WHERE id = $2`, at, userID)
+	if err != nil {
+		return fmt.Errorf("postgres: record local login: %w", err)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// ClickHouse Routes and Tenant Migrations
+// --------------------------------------------------------------------------
+
+// ListClickHouseRoutes returns the ClickHouse cluster of every tenant that
+// is not on the default one.
+func (p *PostgresClient) ListClickHouseRoutes(ctx context.Context) (map[uuid.UUID]string, error) {
+	rows, err := p.pool.Query(ctx, `SELECT tenant_id, cluster FROM tenant_clickhouse_routes`)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list clickhouse routes: %w", err)
+	}
+	defer rows.Close()
+
+	routes := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var tenantID uuid.UUID
+		var cluster string
+		if err := rows.Scan(&tenantID, &cluster); err != nil {
+			return nil, fmt.Errorf("postgres: scan clickhouse route: %w", err)
+		}
+		routes[tenantID] = cluster
+	}
+	return routes, rows.Err()
+}
+
+// SetClickHouseRoute routes a tenant to a ClickHouse cluster.
+func (p *PostgresClient) SetClickHouseRoute(ctx context.Context, tenantID uuid.UUID, cluster string) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO tenant_clickhouse_routes (tenant_id, cluster, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (tenant_id) DO UPDATE SET cluster = EXCLUDED.cluster, updated_at = NOW()
+	`, tenantID, cluster)
+	if err != nil {
+		return fmt.Errorf("postgres: set clickhouse route: %w", err)
+	}
+	return nil
+}
+
+// CreateTenantMigration inserts a running tenant migration.
+// ErrAlreadyExists is returned when the tenant has an unfinished one.
+func (p *PostgresClient) CreateTenantMigration(ctx context.Context, m *domain.TenantMigration) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	m.Status = domain.TenantMigrationRunning
+	m.CreatedAt = now
+	m.UpdatedAt = now
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO tenant_migrations (id, tenant_id, source_cluster, target_cluster, chunk_lines, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, m.ID, m.TenantID, m.SourceCluster, m.TargetCluster, m.ChunkLines, m.Status, m.CreatedAt, m.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("postgres: create tenant migration: %w", err)
+	}
+	return nil
+}
+
+// GetUnfinishedTenantMigration returns the running or failed migration of a
+// tenant.
+func (p *PostgresClient) GetUnfinishedTenantMigration(ctx context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error) {
+	var m domain.TenantMigration
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, tenant_id, source_cluster, target_cluster, chunk_lines, status, error, created_at, updated_at, completed_at
+		FROM tenant_migrations
+		WHERE tenant_id = $1 AND status <> 'completed'
+	`, tenantID).Scan(&m.ID, &m.TenantID, &m.SourceCluster, &m.TargetCluster, &m.ChunkLines, &m.Status, &m.Error,
+		&m.CreatedAt, &m.UpdatedAt, &m.CompletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: unfinished tenant migration not found: %s", tenantID)
+		}
+		return nil, fmt.Errorf("postgres: get tenant migration: %w", err)
+	}
+	return &m, nil
+}
+
+// GetLastCompletedTenantMigration returns the tenant migration that
+// completed last.
+func (p *PostgresClient) GetLastCompletedTenantMigration(ctx context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error) {
+	var m domain.TenantMigration
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, tenant_id, source_cluster, target_cluster, chunk_lines, status, error, created_at, updated_at, completed_at
+		FROM tenant_migrations
+		WHERE tenant_id = $1 AND status = 'completed'
+		ORDER BY completed_at DESC
+		LIMIT 1
+	`, tenantID).Scan(&m.ID, &m.TenantID, &m.SourceCluster, &m.TargetCluster, &m.ChunkLines, &m.Status, &m.Error,
+		&m.CreatedAt, &m.UpdatedAt, &m.CompletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: completed tenant migration not found: %s", tenantID)
+		}
+		return nil, fmt.Errorf("postgres: get tenant migration: %w", err)
+	}
+	return &m, nil
+}
+
+// UpdateTenantMigrationStatus sets the status of a tenant migration, with
+// the error that failed it, and stamps its completion.
+func (p *PostgresClient) UpdateTenantMigrationStatus(ctx context.Context, id uuid.UUID, status domain.TenantMigrationStatus, errMsg string) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE tenant_migrations
+		SET status = $2, error = $3, updated_at = NOW(),
+		    completed_at = CASE WHEN $2 = 'completed' THEN NOW() ELSE NULL END
+		WHERE id = $1
+	`, id, status, errMsg)
+	if err != nil {
+		return fmt.Errorf("postgres: update tenant migration status: %w", err)
+	}
+	return nil
+}
+
+// RecordTenantMigrationChunk records a chunk verified on the target cluster.
+func (p *PostgresClient) RecordTenantMigrationChunk(ctx context.Context, c *domain.TenantMigrationChunk) error {
+	c.VerifiedAt = time.Now().UTC()
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO tenant_migration_chunks (migration_id, table_name, job_id, line_start, rows, checksum, verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (migration_id, table_name, job_id, line_start)
+		DO UPDATE SET rows = EXCLUDED.rows, checksum = EXCLUDED.checksum, verified_at = EXCLUDED.verified_at
+	`, c.MigrationID, c.TableName, c.JobID, c.LineStart, c.Rows, int64(c.Checksum), c.VerifiedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: record tenant migration chunk: %w", err)
+	}
+	return nil
+}
+
+// ListTenantMigrationChunks returns the chunks of a migration verified so
+// far.
+func (p *PostgresClient) ListTenantMigrationChunks(ctx context.Context, migrationID uuid.UUID) ([]domain.TenantMigrationChunk, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT migration_id, table_name, job_id, line_start, rows, checksum, verified_at
+		FROM tenant_migration_chunks
+		WHERE migration_id = $1
+		ORDER BY table_name, job_id, line_start
+	`, migrationID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list tenant migration chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []domain.TenantMigrationChunk
+	for rows.Next() {
+		var c domain.TenantMigrationChunk
+		var checksum int64
+		if err := rows.Scan(&c.MigrationID, &c.TableName, &c.JobID, &c.LineStart, &c.Rows, &checksum, &c.VerifiedAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan tenant migration chunk: %w", err)
+		}
+		c.Checksum = uint64(checksum)
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// --------------------------------------------------------------------------
+// Digest Subscriptions
+// --------------------------------------------------------------------------
+
+const digestSubscriptionColumns = `
+	tenant_id, enabled, recipients, send_hour, timezone, last_period_end, created_at, updated_at`
+
+func scanDigestSubscription(row pgx.Row, s *domain.DigestSubscription) error {
+	return row.Scan(
+		&s.TenantID, &s.Enabled, &s.Recipients, &s.SendHour, &s.Timezone, &s.LastPeriodEnd, &s.CreatedAt, &s.UpdatedAt,
+	)
+}
+
+// GetDigestSubscription retrieves the digest subscription of a tenant.
+func (p *PostgresClient) GetDigestSubscription(ctx context.Context, tenantID uuid.UUID) (*domain.DigestSubscription, error) {
+	var s domain.DigestSubscription
+	err := scanDigestSubscription(p.pool.QueryRow(ctx, `
+		SELECT`+digestSubscriptionColumns+`
+		FROM digest_subscriptions
+		WHERE tenant_id = $1
+	`, tenantID), &s)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: digest subscription not found: %s", tenantID)
+		}
+		return nil, fmt.Errorf("postgres: get digest subscription: %w", err)
+	}
+	return &s, nil
+}
+
+// UpsertDigestSubscription creates or replaces the digest subscription of a
+// tenant. The send history is preserved across updates.
+func (p *PostgresClient) UpsertDigestSubscription(ctx context.Context, s *domain.DigestSubscription) error {
+	now := time.Now().UTC()
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO digest_subscriptions (tenant_id, enabled, recipients, send_hour, timezone, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET enabled = EXCLUDED.enabled, recipients = EXCLUDED.recipients,
+		    send_hour = EXCLUDED.send_hour, timezone = EXCLUDED.timezone,
+		    updated_at = EXCLUDED.updated_at
+		RETURNING last_period_end, created_at, updated_at
+	`, s.TenantID, s.Enabled, s.Recipients, s.SendHour, s.Timezone, now).Scan(&s.LastPeriodEnd, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: upsert digest subscription: %w", err)
+	}
+	return nil
+}
+
+// ListDigestSubscriptions returns the enabled digest subscriptions of all
+// tenants.
+func (p *PostgresClient) ListDigestSubscriptions(ctx context.Context) ([]domain.DigestSubscription, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT`+digestSubscriptionColumns+`
+		FROM digest_subscriptions
+		WHERE enabled AND cardinality(recipients) > 0
+		ORDER BY tenant_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list digest subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []domain.DigestSubscription
+	for rows.Next() {
+		var s domain.DigestSubscription
+		if err := scanDigestSubscription(rows, &s); err != nil {
+			return nil, fmt.Errorf("postgres: scan digest subscription: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// MarkDigestSent records that the digest for the day ending at periodEnd has
+// been produced for the tenant.
+func (p *PostgresClient) MarkDigestSent(ctx context.Context, tenantID uuid.UUID, periodEnd time.Time) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE digest_subscriptions
+		SET last_period_end = $1
+		WHERE tenant_id = $2
+	`, periodEnd, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: mark digest sent: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: digest subscription not found: %s", tenantID)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Search History
+// --------------------------------------------------------------------------
+
+const searchHistoryLimit = 20
+
+func (p *PostgresClient) RecordSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, jobID *uuid.UUID, kqlQuery string, resultCount int) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres: record search history begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO search_history (tenant_id, user_id, job_id, kql_query, result_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, tenantID, userID, jobID, kqlQuery, resultCount, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("postgres: record search history insert: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM search_history
+		WHERE tenant_id = $1 AND user_id = $2
+		  AND id NOT IN (
+		    SELECT id FROM search_history
+		    WHERE tenant_id = $1 AND user_id = $2
+		    ORDER BY created_at DESC
+		    LIMIT $3
+		  )
+	`, tenantID, userID, searchHistoryLimit)
+	if err != nil {
+		return fmt.Errorf("postgres: record search history cleanup: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("postgres: record search history commit: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresClient) GetSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, limit int) ([]domain.SearchHistoryEntry, error) {
+	if limit <= 0 || limit > searchHistoryLimit {
+		limit = searchHistoryLimit
+	}
+
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT id, tenant_id, user_id, job_id, kql_query, result_count, created_at
+		FROM search_history
+		WHERE tenant_id = $1 AND user_id = $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, tenantID, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: get search history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []domain.SearchHistoryEntry
+	for rows.Next() {
+		var e domain.SearchHistoryEntry
+		if err := rows.Scan(
+			&e.ID, &e.TenantID, &e.UserID, &e.JobID, &e.KQLQuery, &e.ResultCount, &e.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("postgres: scan search history: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *PostgresClient) CreateConversation(ctx context.Context, c *domain.Conversation) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	now := time.Now().UTC()
+	c.CreatedAt = now
+	c.UpdatedAt = now
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO conversations (id, tenant_id, user_id, job_id, title, created_at, updated_at, message_count, last_message_at, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, c.ID, c.TenantID, c.UserID, c.JobID, c.Title, c.CreatedAt, c.UpdatedAt, c.MessageCount, c.LastMessageAt, c.Metadata)
+	if err != nil {
+		return fmt.Errorf("postgres: create conversation: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresClient) GetConversation(ctx context.Context, tenantID, conversationID uuid.UUID) (*domain.Conversation, error) {
+	var c domain.Conversation
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, tenant_id, user_id, job_id, title, created_at, updated_at, message_count, last_message_at, metadata
+		FROM conversations
+		WHERE id = $1 AND tenant_id = $2
+	`, conversationID, tenantID).Scan(
+		&c.ID, &c.TenantID, &c.UserID, &c.JobID, &c.Title,
+		&c.CreatedAt, &c.UpdatedAt, &c.MessageCount, &c.LastMessageAt, &c.Metadata,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: conversation not found: %s", conversationID)
+		}
+		return nil, fmt.Errorf("postgres: get conversation: %w", err)
+	}
+	return &c, nil
+}
+
+func (p *PostgresClient) GetConversationWithMessages(ctx context.Context, tenantID, conversationID uuid.UUID, limit int) (*domain.Conversation, error) {
+	c, err := p.GetConversation(ctx, tenantID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = 50
+	}
+
 	rows, err := p.pool.Query(ctx, `
-		SELECT id, tenant_id, user_id, name, kql_query, filters, is_pinned, created_at
-		FROM saved_searches
-		WHERE tenant_id = $1 AND user_id = $2
-		ORDER BY is_pinned DESC, created_at DESC
-	`, tenantID, userID)
+		SELECT id, conversation_id, tenant_id, role, content, skill_name, follow_ups, tokens_used, latency_ms, status, error_message, created_at
+		FROM messages
+		WHERE conversation_id = $1 AND tenant_id = $2
+		ORDER BY created_at ASC
+		LIMIT $3
+	`, conversationID, tenantID, limit)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: list saved searches: %w", err)
+		return nil, fmt.Errorf("postgres: get conversation messages: %w", err)
 	}
 	defer rows.Close()
 
-	var searches []domain.SavedSearch
 	for rows.Next() {
-		var s domain.SavedSearch
+		var m domain.Message
 		if err := rows.Scan(
-			&s.ID, &s.TenantID, &s.UserID, &s.Name, &s.KQLQuery,
-			&s.Filters, &s.IsPinned, &s.CreatedAt,
+			&m.ID, &m.ConversationID, &m.TenantID, &m.Role, &m.Content,
+			&m.SkillName, &m.FollowUps, &m.TokensUsed, &m.LatencyMS,
+			&m.Status, &m.ErrorMessage, &m.CreatedAt,
 		); err != nil {
-			return nil, fmt.Errorf("postgres: scan saved search: %w", err)
+			return nil, fmt.Errorf("postgres: scan message: %w", err)
 		}
-		searches = append(searches, s)
+		c.Messages = append(c.Messages, m)
 	}
-	return searches, rows.Err()
+	return c, rows.Err()
 }
 
-// DeleteSavedSearch removes a saved search by ID, scoped to the tenant and user.
-func (p *PostgresClient) DeleteSavedSearch(ctx context.Context, tenantID uuid.UUID, userID string, searchID uuid.UUID) error {
+func (p *PostgresClient) ListConversations(ctx context.Context, tenantID uuid.UUID, userID string, jobID uuid.UUID, limit int) ([]domain.Conversation, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tenant_id, user_id, job_id, title, created_at, updated_at, message_count, last_message_at, metadata
+		FROM conversations
+		WHERE tenant_id = $1 AND user_id = $2 AND job_id = $3
+		ORDER BY updated_at DESC
+		LIMIT $4
+	`, tenantID, userID, jobID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []domain.Conversation
+	for rows.Next() {
+		var c domain.Conversation
+		if err := rows.Scan(
+			&c.ID, &c.TenantID, &c.UserID, &c.JobID, &c.Title,
+			&c.CreatedAt, &c.UpdatedAt, &c.MessageCount, &c.LastMessageAt, &c.Metadata,
+		); err != nil {
+			return nil, fmt.Errorf("postgres: scan conversation: %w", err)
+		}
+		conversations = append(conversations, c)
+	}
+	return conversations, rows.Err()
+}
+
+func (p *PostgresClient) DeleteConversation(ctx context.Context, tenantID, conversationID uuid.UUID) error {
 	tag, err := p.pool.Exec(ctx, `
-		DELETE FROM saved_searches
-		WHERE id = $1 AND tenant_id = $2 AND user_id = $3
-	`, searchID, tenantID, userID)
+		DELETE FROM conversations
+		WHERE id = $1 AND tenant_id = $2
+	`, conversationID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: delete conversation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: conversation not found: %s", conversationID)
+	}
+	return nil
+}
+
+func (p *PostgresClient) AddMessage(ctx context.Context, m *domain.Message) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	m.CreatedAt = time.Now().UTC()
+	if m.Status == "" {
+		m.Status = domain.MessageStatusPending
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO messages (id, conversation_id, tenant_id, role, content, skill_name, follow_ups, tokens_used, latency_ms, status, error_message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, m.ID, m.ConversationID, m.TenantID, m.Role, m.Content,
+		m.SkillName, m.FollowUps, m.TokensUsed, m.LatencyMS,
+		m.Status, m.ErrorMessage, m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: add message: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresClient) GetMessages(ctx context.Context, tenantID, conversationID uuid.UUID, limit int) ([]domain.Message, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, conversation_id, tenant_id, role, content, skill_name, follow_ups, tokens_used, latency_ms, status, error_message, created_at
+		FROM messages
+		WHERE conversation_id = $1 AND tenant_id = $2
+		ORDER BY created_at ASC
+		LIMIT $3
+	`, conversationID, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: get messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []domain.Message
+	for rows.Next() {
+		var m domain.Message
+		if err := rows.Scan(
+			&m.ID, &m.ConversationID, &m.TenantID, &m.Role, &m.Content,
+			&m.SkillName, &m.FollowUps, &m.TokensUsed, &m.LatencyMS,
+			&m.Status, &m.ErrorMessage, &m.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("postgres: scan message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func (p *PostgresClient) UpdateMessageStatus(ctx context.Context, tenantID, messageID uuid.UUID, status domain.MessageStatus, errorMessage string) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE messages
+		SET status = $1, error_message = $2
+		WHERE id = $3 AND tenant_id = $4
+	`, status, errorMessage, messageID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update message status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: message not found: %s", messageID)
+	}
+	return nil
+}
+
+func (p *PostgresClient) UpdateMessageContent(ctx context.Context, tenantID, messageID uuid.UUID, content string, tokensUsed, latencyMS int, status domain.MessageStatus, followUps []string) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE messages
+		SET content = $1, tokens_used = $2, latency_ms = $3, status = $4, follow_ups = $5
+		WHERE id = $6 AND tenant_id = $7
+	`, content, tokensUsed, latencyMS, status, followUps, messageID, tenantID)
+	if err != nil {
+		return fmt.Errorf("postgres: update message content: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: message not found: %s", messageID)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Health Profiles
+// --------------------------------------------------------------------------
+
+// healthProfileConfig is the JSONB document a health profile version is
+// stored as.
+type healthProfileConfig struct {
+	Factors    []domain.HealthFactorConfig `json:"factors"`
+	RedBelow   int                         `json:"red_below"`
+	GreenAbove int                         `json:"green_above"`
+}
+
+func scanHealthProfile(row pgx.Row, hp *domain.HealthProfile) error {
+	var raw []byte
+	if err := row.Scan(&hp.TenantID, &hp.Version, &raw, &hp.CreatedBy, &hp.CreatedAt); err != nil {
+		return err
+	}
+	var cfg healthProfileConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return fmt.Errorf("decode health profile config: %w", err)
+	}
+	hp.Factors, hp.RedBelow, hp.GreenAbove = cfg.Factors, cfg.RedBelow, cfg.GreenAbove
+	return nil
+}
+
+// GetHealthProfile retrieves the latest health profile version of a tenant.
+func (p *PostgresClient) GetHealthProfile(ctx context.Context, tenantID uuid.UUID) (*domain.HealthProfile, error) {
+	var hp domain.HealthProfile
+	err := scanHealthProfile(p.pool.QueryRow(ctx, `
+		SELECT tenant_id, version, config, created_by, created_at
+		FROM health_profiles
+		WHERE tenant_id = $1
+		ORDER BY version DESC
+		LIMIT 1
+	`, tenantID), &hp)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: health profile not found: %s", tenantID)
+		}
+		return nil, fmt.Errorf("postgres: get health profile: %w", err)
+	}
+	return &hp, nil
+}
+
+// GetHealthProfileVersion retrieves one stored version of a tenant's health
+// profile.
+func (p *PostgresClient) GetHealthProfileVersion(ctx context.Context, tenantID uuid.UUID, version int) (*domain.HealthProfile, error) {
+	var hp domain.HealthProfile
+	err := scanHealthProfile(p.pool.QueryRow(ctx, `
+		SELECT tenant_id, version, config, created_by, created_at
+		FROM health_profiles
+		WHERE tenant_id = $1 AND version = $2
+	`, tenantID, version), &hp)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: health profile not found: %s v%d", tenantID, version)
+		}
+		return nil, fmt.Errorf("postgres: get health profile version: %w", err)
+	}
+	return &hp, nil
+}
+
+// CreateHealthProfile stores hp as the next version of the tenant's health
+// profile if the latest stored version still equals expectedVersion (0 when
+// the tenant has none). Versions are never modified once written. On success
+// hp.Version and hp.CreatedAt are set; ErrVersionConflict is returned when
+// another version was written first.
+func (p *PostgresClient) CreateHealthProfile(ctx context.Context, hp *domain.HealthProfile, expectedVersion int) error {
+	raw, err := json.Marshal(healthProfileConfig{Factors: hp.Factors, RedBelow: hp.RedBelow, GreenAbove: hp.GreenAbove})
+	if err != nil {
+		return fmt.Errorf("postgres: encode health profile: %w", err)
+	}
+
+	// The insert only happens when the latest version matches; two writers
+	// racing past the check collide on the primary key.
+	err = p.pool.QueryRow(ctx, `
+		INSERT INTO health_profiles (tenant_id, version, config, created_by)
+		SELECT $1, $2 + 1, $3, $4
+		WHERE COALESCE((SELECT MAX(version) FROM health_profiles WHERE tenant_id = $1), 0) = $2
+		RETURNING version, created_at
+	`, hp.TenantID, expectedVersion, raw, hp.CreatedBy).Scan(&hp.Version, &hp.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if err == pgx.ErrNoRows || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+			return ErrVersionConflict
+		}
+		return fmt.Errorf("postgres: create health profile: %w", err)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Idempotency Keys
+// --------------------------------------------------------------------------
+
+// BeginIdempotentRequest claims the idempotency key of rec for the request
+// hashed to rec.RequestHash until rec.LockedUntil, the key being unused,
+// expired at rec.CreatedAt or held by an in-flight request whose claim
+// lapsed. When the key is held the stored record is returned instead, in
+// flight or with its response, and claimed is false. rec.CreatedAt
+// identifies the claim and is truncated to the precision it is stored with.
+func (p *PostgresClient) BeginIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) (stored *domain.IdempotencyRecord, claimed bool, err error) {
+	rec.CreatedAt = rec.CreatedAt.UTC().Truncate(time.Microsecond)
+	// The key can be released between the claim failing and the record
+	// being read; the claim is then tried again.
+	for attempt := 0; attempt < 3; attempt++ {
+		tag, err := p.pool.Exec(ctx, `
+			INSERT INTO idempotency_keys
+				(tenant_id, endpoint, idempotency_key, request_hash, created_at, locked_until, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (tenant_id, endpoint, idempotency_key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash,
+			    status_code = NULL,
+			    content_type = '',
+			    response_body = NULL,
+			    created_at = EXCLUDED.created_at,
+			    locked_until = EXCLUDED.locked_until,
+			    expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+			   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= EXCLUDED.created_at)
+		`, rec.TenantID, rec.Endpoint, rec.Key, rec.RequestHash, rec.CreatedAt, rec.LockedUntil, rec.ExpiresAt)
+		if err != nil {
+			return nil, false, fmt.Errorf("postgres: begin idempotent request: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			return nil, true, nil
+		}
+
+		existing := domain.IdempotencyRecord{TenantID: rec.TenantID, Endpoint: rec.Endpoint, Key: rec.Key}
+		var status *int
+		err = p.pool.QueryRow(ctx, `
+			SELECT request_hash, status_code, content_type, response_body, created_at, locked_until, expires_at
+			FROM idempotency_keys
+			WHERE tenant_id = $1 AND endpoint = $2 AND idempotency_key = $3
+		`, rec.TenantID, rec.Endpoint, rec.Key).Scan(
+			&existing.RequestHash, &status, &existing.ContentType, &existing.ResponseBody,
+			&existing.CreatedAt, &existing.LockedUntil, &existing.ExpiresAt,
+		)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("postgres: get idempotency key: %w", err)
+		}
+		if status != nil {
+			existing.StatusCode = *status
+		}
+		return &existing, false, nil
+	}
+	return nil, false, fmt.Errorf("postgres: begin idempotent request: key %q keeps being released", rec.Key)
+}
+
+// CompleteIdempotentRequest stores the response of the request that
+// claimed the idempotency key of rec. It returns a "not found" error when
+// the claim lapsed and the key was claimed again.
+func (p *PostgresClient) CompleteIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $5, content_type = $6, response_body = $7
+		WHERE tenant_id = $1 AND endpoint = $2 AND idempotency_key = $3
+		  AND request_hash = $4 AND created_at = $8 AND status_code IS NULL
+	`, rec.TenantID, rec.Endpoint, rec.Key, rec.RequestHash, rec.StatusCode, rec.ContentType, rec.ResponseBody, rec.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: complete idempotent request: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("postgres: idempotency key claim not found: %s", rec.Key)
+	}
+	return nil
+}
+
+// ReleaseIdempotentRequest gives up the claim of the request on the
+// idempotency key of rec without storing a response, so that the request
+// can be retried with the key.
+func (p *PostgresClient) ReleaseIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	_, err := p.pool.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE tenant_id = $1 AND endpoint = $2 AND idempotency_key = $3
+		  AND created_at = $4 AND status_code IS NULL
+	`, rec.TenantID, rec.Endpoint, rec.Key, rec.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres: release idempotent request: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes the idempotency keys of every
+// tenant that expired before before, returning how many were deleted.
+func (p *PostgresClient) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("postgres: delete expired idempotency keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// --------------------------------------------------------------------------
+// Background Tasks
+// --------------------------------------------------------------------------
+
+// maxBackgroundTaskRuns is the number of latest runs kept per task.
+const maxBackgroundTaskRuns = 100
+
+// backgroundTaskColumns are the columns scanned by scanBackgroundTask.
+const backgroundTaskColumns = `name, schedule, timeout_seconds, next_run_at, registered_at,
+	run_requested_at, run_requested_by, locked_by, locked_until,
+	COALESCE(locked_until > NOW(), false)`
+
+func scanBackgroundTask(row pgx.Row) (*domain.BackgroundTask, error) {
+	var t domain.BackgroundTask
+	err := row.Scan(&t.Name, &t.Schedule, &t.TimeoutSeconds, &t.NextRunAt, &t.RegisteredAt,
+		&t.RunRequestedAt, &t.RunRequestedBy, &t.LockedBy, &t.LockedUntil, &t.Running)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RegisterBackgroundTask records a task a worker runs. A new task is first
+// due at task.NextRunAt; a known one keeps its next run unless its schedule
+// changed.
+func (p *PostgresClient) RegisterBackgroundTask(ctx context.Context, task *domain.BackgroundTask) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO background_tasks (name, schedule, timeout_seconds, next_run_at, registered_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (name) DO UPDATE
+		SET next_run_at = CASE WHEN background_tasks.schedule = EXCLUDED.schedule
+		                       THEN background_tasks.next_run_at ELSE EXCLUDED.next_run_at END,
+		    schedule = EXCLUDED.schedule,
+		    timeout_seconds = EXCLUDED.timeout_seconds,
+		    registered_at = NOW()
+	`, task.Name, task.Schedule, task.TimeoutSeconds, task.NextRunAt)
+	if err != nil {
+		return fmt.Errorf("postgres: register background task: %w", err)
+	}
+	return nil
+}
+
+// DueBackgroundTasks returns the names of the tasks due at now, on schedule
+// or on request, that no worker holds.
+func (p *PostgresClient) DueBackgroundTasks(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT name FROM background_tasks
+		WHERE (next_run_at <= $1 OR run_requested_at IS NOT NULL)
+		  AND (locked_until IS NULL OR locked_until <= $1)
+		ORDER BY next_run_at
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: due background tasks: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("postgres: due background tasks: %w", err)
+	}
+	return names, nil
+}
+
+// ClaimBackgroundTask takes the lock of task name for workerID until
+// lockedUntil, provided the task is due at now and no other worker holds
+// it. A run on schedule moves the next run to nextRunAt; a requested run
+// leaves the schedule alone unless it was due as well. It returns the
+// trigger of the run, domain.TaskTriggerManual when it was requested.
+func (p *PostgresClient) ClaimBackgroundTask(ctx context.Context, name, workerID string, now, lockedUntil, nextRunAt time.Time) (string, bool, error) {
+	var manual bool
+	err := p.pool.QueryRow(ctx, `
+		WITH prev AS (
+			SELECT name, next_run_at, run_requested_at
+			FROM background_tasks
+			WHERE name = $1
+			FOR UPDATE
+		)
+		UPDATE background_tasks t
+		SET locked_by = $2, locked_until = $4,
+		    run_requested_at = NULL, run_requested_by = '',
+		    next_run_at = CASE WHEN prev.next_run_at <= $3 THEN $5 ELSE prev.next_run_at END
+		FROM prev
+		WHERE t.name = prev.name
+		  AND (prev.next_run_at <= $3 OR prev.run_requested_at IS NOT NULL)
+		  AND (t.locked_until IS NULL OR t.locked_until <= $3)
+		RETURNING prev.run_requested_at IS NOT NULL
+	`, name, workerID, now, lockedUntil, nextRunAt).Scan(&manual)
+	if err == pgx.ErrNoRows {
+		return "", false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("postgres: delete saved search: %w", err)
+		return "", false, fmt.Errorf("postgres: claim background task: %w", err)
 	}
-	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("postgres: saved search not found: %s", searchID)
+	if manual {
+		return domain.TaskTriggerManual, true, nil
 	}
-	return nil
+	return domain.TaskTriggerSchedule, true, nil
 }
 
-// --------------------------------------------------------------------------
-// Search History
-// --------------------------------------------------------------------------
-
-const searchHistoryLimit = 20
-
-func (p *PostgresClient) RecordSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, jobID *uuid.UUID, kqlQuery string, resultCount int) error {
+// FinishBackgroundTaskRun records a finished run and releases the lock its
+// worker held, keeping the latest maxBackgroundTaskRuns runs of the task.
+func (p *PostgresClient) FinishBackgroundTaskRun(ctx context.Context, run *domain.BackgroundTaskRun) error {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("postgres: record search history begin: %w", err)
+		return fmt.Errorf("postgres: finish background task run begin: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO search_history (tenant_id, user_id, job_id, kql_query, result_count, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, tenantID, userID, jobID, kqlQuery, resultCount, time.Now().UTC())
+	err = tx.QueryRow(ctx, `
+		INSERT INTO background_task_runs
+			(task_name, trigger, worker_id, started_at, finished_at, duration_ms, outcome, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, run.TaskName, run.Trigger, run.WorkerID, run.StartedAt, run.FinishedAt, run.DurationMS, run.Outcome, run.Error).Scan(&run.ID)
 	if err != nil {
-		return fmt.Errorf("postgres: record search history insert: %w", err)
+		return fmt.Errorf("postgres: record background task run: %w", err)
 	}
-
-	_, err = tx.Exec(ctx, `
-		DELETE FROM search_history
-		WHERE tenant_id = $1 AND user_id = $2
-		  AND id NOT IN (
-		    SELECT id FROM search_history
-		    WHERE tenant_id = $1 AND user_id = $2
-		    ORDER BY created_at DESC
-		    LIMIT $3
-		  )
-	`, tenantID, userID, searchHistoryLimit)
-	if err != nil {
-		return fmt.Errorf("postgres: record search history cleanup: %w", err)
+	if _, err := tx.Exec(ctx, `
+		UPDATE background_tasks SET locked_by = '', locked_until = NULL
+		WHERE name = $1 AND locked_by = $2
+	`, run.TaskName, run.WorkerID); err != nil {
+		return fmt.Errorf("postgres: release background task: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM background_task_runs
+		WHERE task_name = $1 AND id NOT IN (
+			SELECT id FROM background_task_runs WHERE task_name = $1
+			ORDER BY started_at DESC, id DESC
+			LIMIT $2
+		)
+	`, run.TaskName, maxBackgroundTaskRuns); err != nil {
+		return fmt.Errorf("postgres: prune background task runs: %w", err)
 	}
-
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("postgres: record search history commit: %w", err)
+		return fmt.Errorf("postgres: finish background task run commit: %w", err)
 	}
 	return nil
 }
 
-func (p *PostgresClient) GetSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, limit int) ([]domain.SearchHistoryEntry, error) {
-	if limit <= 0 || limit > searchHistoryLimit {
-		limit = searchHistoryLimit
-	}
-
+// ListBackgroundTasks returns every registered task with its latest run,
+// by name.
+func (p *PostgresClient) ListBackgroundTasks(ctx context.Context) ([]domain.BackgroundTask, error) {
 	rows, err := p.pool.Query(ctx, `
-		SELECT id, tenant_id, user_id, job_id, kql_query, result_count, created_at
-		FROM search_history
-		WHERE tenant_id = $1 AND user_id = $2
-		ORDER BY created_at DESC
-		LIMIT $3
-	`, tenantID, userID, limit)
+		SELECT `+backgroundTaskColumns+`,
+		       r.id, r.trigger, r.worker_id, r.started_at, r.finished_at, r.duration_ms, r.outcome, r.error
+		FROM background_tasks
+		LEFT JOIN LATERAL (
+			SELECT * FROM background_task_runs
+			WHERE task_name = background_tasks.name
+			ORDER BY started_at DESC, id DESC
+			LIMIT 1
+		) r ON true
+		ORDER BY name
+	`)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: get search history: %w", err)
+		return nil, fmt.Errorf("postgres: list background tasks: %w", err)
 	}
 	defer rows.Close()
 
-	var entries []domain.SearchHistoryEntry
+	var tasks []domain.BackgroundTask
 	for rows.Next() {
-		var e domain.SearchHistoryEntry
-		if err := rows.Scan(
-			&e.ID, &e.TenantID, &e.UserID, &e.JobID, &e.KQLQuery, &e.ResultCount, &e.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("postgres: scan search history: %w", err)
+		var t domain.BackgroundTask
+		var (
+			runID                 *int64
+			trigger, worker       *string
+			startedAt, finishedAt *time.Time
+			durationMS            *int64
+			outcome, runErr       *string
+		)
+		if err := rows.Scan(&t.Name, &t.Schedule, &t.TimeoutSeconds, &t.NextRunAt, &t.RegisteredAt,
+			&t.RunRequestedAt, &t.RunRequestedBy, &t.LockedBy, &t.LockedUntil, &t.Running,
+			&runID, &trigger, &worker, &startedAt, &finishedAt, &durationMS, &outcome, &runErr); err != nil {
+			return nil, fmt.Errorf("postgres: scan background task: %w", err)
 		}
-		entries = append(entries, e)
+		if runID != nil {
+			t.LastRun = &domain.BackgroundTaskRun{
+				ID: *runID, TaskName: t.Name, Trigger: *trigger, WorkerID: *worker,
+				StartedAt: *startedAt, FinishedAt: *finishedAt, DurationMS: *durationMS,
+				Outcome: *outcome, Error: *runErr,
+			}
+		}
+		tasks = append(tasks, t)
 	}
-	return entries, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: list background tasks: %w", err)
+	}
+	return tasks, nil
 }
 
-func (p *PostgresClient) CreateConversation(ctx context.Context, c *domain.Conversation) error {
-	if c.ID == uuid.Nil {
-		c.ID = uuid.New()
+// RequestBackgroundTaskRun asks for a run of task name outside its
+// schedule. A request not yet started is kept as it is. The run starts at
+// the next poll of a worker, or after the run in flight finishes.
+func (p *PostgresClient) RequestBackgroundTaskRun(ctx context.Context, name, requestedBy string) (*domain.BackgroundTask, error) {
+	task, err := scanBackgroundTask(p.pool.QueryRow(ctx, `
+		UPDATE background_tasks
+		SET run_requested_by = CASE WHEN run_requested_at IS NULL THEN $2 ELSE run_requested_by END,
+		    run_requested_at = COALESCE(run_requested_at, NOW())
+		WHERE name = $1
+		RETURNING `+backgroundTaskColumns, name, requestedBy))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("postgres: background task not found: %s", name)
 	}
-	now := time.Now().UTC()
-	c.CreatedAt = now
-	c.UpdatedAt = now
-
-	_, err := p.pool.Exec(ctx, `
-		INSERT INTO conversations (id, tenant_id, user_id, job_id, title, created_at, updated_at, message_count, last_message_at, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, c.ID, c.TenantID, c.UserID, c.JobID, c.Title, c.CreatedAt, c.UpdatedAt, c.MessageCount, c.LastMessageAt, c.Metadata)
 	if err != nil {
-		return fmt.Errorf("postgres: create conversation: %w", err)
+		return nil, fmt.Errorf("postgres: request background task run: %w", err)
 	}
-	return nil
+	return task, nil
 }
 
-func (p *PostgresClient) GetConversation(ctx context.Context, tenantID, conversationID uuid.UUID) (*domain.Conversation, error) {
-	var c domain.Conversation
+// --------------------------------------------------------------------------
+// Server Settings
+// --------------------------------------------------------------------------
+
+// GetServerSettings retrieves the latest version of the dynamic server
+// settings, or version 0 without settings when none is stored.
+func (p *PostgresClient) GetServerSettings(ctx context.Context) (*domain.ServerSettings, error) {
+	s := domain.ServerSettings{Settings: map[string]json.RawMessage{}}
+	var raw []byte
+	var createdAt time.Time
 	err := p.pool.QueryRow(ctx, `
-		SELECT id, tenant_id, user_id, job_id, title, created_at, updated_at, message_count, last_message_at, metadata
-		FROM conversations
-		WHERE id = $1 AND tenant_id = $2
-	`, conversationID, tenantID).Scan(
-		&c.ID, &c.TenantID, &c.UserID, &c.JobID, &c.Title,
-		&c.CreatedAt, &c.UpdatedAt, &c.MessageCount, &c.LastMessageAt, &c.Metadata,
-	)
+		SELECT version, settings, created_by, created_at
+		FROM server_settings
+		ORDER BY version DESC
+		LIMIT 1
+	`).Scan(&s.Version, &raw, &s.CreatedBy, &createdAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("postgres: conversation not found: %s", conversationID)
+			return &s, nil
 		}
-		return nil, fmt.Errorf("postgres: get conversation: %w", err)
+		return nil, fmt.Errorf("postgres: get server settings: %w", err)
 	}
-	return &c, nil
+	if err := json.Unmarshal(raw, &s.Settings); err != nil {
+		return nil, fmt.Errorf("postgres: decode server settings: %w", err)
+	}
+	s.CreatedAt = &createdAt
+	return &s, nil
 }
 
-func (p *PostgresClient) GetConversationWithMessages(ctx context.Context, tenantID, conversationID uuid.UUID, limit int) (*domain.Conversation, error) {
-	c, err := p.GetConversation(ctx, tenantID, conversationID)
+// CreateServerSettings stores s as the next version of the dynamic server
+// settings if the latest stored version still equals expectedVersion (0
+// when none is stored). Versions are never modified once written. On
+// success s.Version and s.CreatedAt are set; ErrVersionConflict is returned
+// when another version was written first.
+func (p *PostgresClient) CreateServerSettings(ctx context.Context, s *domain.ServerSettings, expectedVersion int) error {
+	raw, err := json.Marshal(s.Settings)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("postgres: encode server settings: %w", err)
 	}
 
-	if limit <= 0 {
-		limit = 50
+	// As with health profiles, two writers racing past the check collide
+	// on the primary key.
+	var createdAt time.Time
+	err = p.pool.QueryRow(ctx, `
+		INSERT INTO server_settings (version, settings, created_by)
+		SELECT $1 + 1, $2, $3
+		WHERE COALESCE((SELECT MAX(version) FROM server_settings), 0) = $1
+		RETURNING version, created_at
+	`, expectedVersion, raw, s.CreatedBy).Scan(&s.Version, &createdAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if err == pgx.ErrNoRows || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+			return ErrVersionConflict
+		}
+		return fmt.Errorf("postgres: create server settings: %w", err)
 	}
+	s.CreatedAt = &createdAt
+	return nil
+}
 
-	rows, err := p.pool.Query(ctx, `
-		SELECT id, conversation_id, tenant_id, role, content, skill_name, follow_ups, tokens_used, latency_ms, status, error_message, created_at
-		FROM messages
-		WHERE conversation_id = $1 AND tenant_id = $2
-		ORDER BY created_at ASC
-		LIMIT $3
-	`, conversationID, tenantID, limit)
+// --------------------------------------------------------------------------
+// User Preferences
+// --------------------------------------------------------------------------
+
+// GetPreferences retrieves the preferences of a user in a namespace, or the
+// tenant defaults of the namespace when userID is empty.
+func (p *PostgresClient) GetPreferences(ctx context.Context, tenantID uuid.UUID, userID, namespace string) (*domain.Preferences, error) {
+	prefs := domain.Preferences{TenantID: tenantID, UserID: userID, Namespace: namespace}
+	var doc []byte
+	err := p.pool.QueryRow(ctx, `
+		SELECT schema_version, document, revision, updated_by, updated_at
+		FROM user_preferences
+		WHERE tenant_id = $1 AND user_id = $2 AND namespace = $3
+	`, tenantID, userID, namespace).Scan(&prefs.SchemaVersion, &doc, &prefs.Revision, &prefs.UpdatedBy, &prefs.UpdatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: get conversation messages: %w", err)
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: preferences not found: %s/%s", userID, namespace)
+		}
+		return nil, fmt.Errorf("postgres: get preferences: %w", err)
 	}
-	defer rows.Close()
+	prefs.Document = doc
+	return &prefs, nil
+}
 
-	for rows.Next() {
-		var m domain.Message
-		if err := rows.Scan(
-			&m.ID, &m.ConversationID, &m.TenantID, &m.Role, &m.Content,
-			&m.SkillName, &m.FollowUps, &m.TokensUsed, &m.LatencyMS,
-			&m.Status, &m.ErrorMessage, &m.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("postgres: scan message: %w", err)
+// PutPreferences stores prefs if the stored revision still equals
+// expectedRevision (0 when nothing is stored yet). On success prefs.Revision
+// and prefs.UpdatedAt are set; ErrVersionConflict is returned when another
+// write came first.
+func (p *PostgresClient) PutPreferences(ctx context.Context, prefs *domain.Preferences, expectedRevision int) error {
+	var row pgx.Row
+	if expectedRevision == 0 {
+		row = p.pool.QueryRow(ctx, `
+			INSERT INTO user_preferences (tenant_id, user_id, namespace, schema_version, document, revision, updated_by)
+			VALUES ($1, $2, $3, $4, $5, 1, $6)
+			ON CONFLICT (tenant_id, user_id, namespace) DO NOTHING
+			RETURNING revision, updated_at
+		`, prefs.TenantID, prefs.UserID, prefs.Namespace, prefs.SchemaVersion, []byte(prefs.Document), prefs.UpdatedBy)
+	} else {
+		row = p.pool.QueryRow(ctx, `
+			UPDATE user_preferences
+			SET schema_version = $4, document = $5, revision = revision + 1, updated_by = $6, updated_at = NOW()
+			WHERE tenant_id = $1 AND user_id = $2 AND namespace = $3 AND revision = $7
+			RETURNING revision, updated_at
+		`, prefs.TenantID, prefs.UserID, prefs.Namespace, prefs.SchemaVersion, []byte(prefs.Document), prefs.UpdatedBy, expectedRevision)
+	}
+	if err := row.Scan(&prefs.Revision, &prefs.UpdatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrVersionConflict
 		}
-		c.Messages = append(c.Messages, m)
+		return fmt.Errorf("postgres: put preferences: %w", err)
 	}
-	return c, rows.Err()
+	return nil
 }
 
-func (p *PostgresClient) ListConversations(ctx context.Context, tenantID uuid.UUID, userID string, jobID uuid.UUID, limit int) ([]domain.Conversation, error) {
-	if limit <= 0 {
-		limit = 20
+// workspaceColumns are the columns scanWorkspace reads.
+const workspaceColumns = `id, tenant_id, job_id, name, owner_id, sharing, schema_version, state, version, created_at, updated_at, updated_by`
+
+func scanWorkspace(row pgx.Row) (*domain.Workspace, error) {
+	var ws domain.Workspace
+	var state []byte
+	if err := row.Scan(&ws.ID, &ws.TenantID, &ws.JobID, &ws.Name, &ws.OwnerID, &ws.Sharing,
+		&ws.SchemaVersion, &state, &ws.Version, &ws.CreatedAt, &ws.UpdatedAt, &ws.UpdatedBy); err != nil {
+		return nil, err
+	}
+	ws.State = state
+	return &ws, nil
+}
+
+// CreateWorkspace stores a new investigation workspace at version 1,
+// setting its ID when unset and its timestamps.
+func (p *PostgresClient) CreateWorkspace(ctx context.Context, ws *domain.Workspace) error {
+	if ws.ID == uuid.Nil {
+		ws.ID = uuid.New()
+	}
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO investigation_workspaces (id, tenant_id, job_id, name, owner_id, sharing, schema_version, state, version, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1, $5)
+		RETURNING version, created_at, updated_at, updated_by
+	`, ws.ID, ws.TenantID, ws.JobID, ws.Name, ws.OwnerID, ws.Sharing, ws.SchemaVersion, []byte(ws.State)).
+		Scan(&ws.Version, &ws.CreatedAt, &ws.UpdatedAt, &ws.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("postgres: create workspace: %w", err)
+	}
+	return nil
+}
+
+// GetWorkspace returns a workspace of an analysis, whoever may see it.
+func (p *PostgresClient) GetWorkspace(ctx context.Context, tenantID, jobID, workspaceID uuid.UUID) (*domain.Workspace, error) {
+	ws, err := scanWorkspace(p.pool.QueryRow(ctx, `
+		SELECT `+workspaceColumns+`
+		FROM investigation_workspaces
+		WHERE id = $1 AND job_id = $2 AND tenant_id = $3
+	`, workspaceID, jobID, tenantID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("postgres: workspace not found: %s", workspaceID)
+		}
+		return nil, fmt.Errorf("postgres: get workspace: %w", err)
 	}
+	return ws, nil
+}
 
+// ListWorkspaces returns the workspaces of an analysis that userID owns or
+// that are shared with the tenant, most recently updated first.
+func (p *PostgresClient) ListWorkspaces(ctx context.Context, tenantID, jobID uuid.UUID, userID string) ([]domain.Workspace, error) {
 	rows, err := p.pool.Query(ctx, `
-		SELECT id, tenant_id, user_id, job_id, title, created_at, updated_at, message_count, last_message_at, metadata
-		FROM conversations
-		WHERE tenant_id = $1 AND user_id = $2 AND job_id = $3
-		ORDER BY updated_at DESC
-		LIMIT $4
-	`, tenantID, userID, jobID, limit)
+		SELECT `+workspaceColumns+`
+		FROM investigation_workspaces
+		WHERE tenant_id = $1 AND job_id = $2 AND (owner_id = $3 OR sharing <> 'private')
+		ORDER BY updated_at DESC, id
+	`, tenantID, jobID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: list conversations: %w", err)
+		return nil, fmt.Errorf("postgres: list workspaces: %w", err)
 	}
 	defer rows.Close()
 
-	var conversations []domain.Conversation
+	workspaces := []domain.Workspace{}
 	for rows.Next() {
-		var c domain.Conversation
-		if err := rows.Scan(
-			&c.ID, &c.TenantID, &c.UserID, &c.JobID, &c.Title,
-			&c.CreatedAt, &c.UpdatedAt, &c.MessageCount, &c.LastMessageAt, &c.Metadata,
-		); err != nil {
-			return nil, fmt.Errorf("postgres: scan conversation: %w", err)
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres: scan workspace: %w", err)
 		}
-		conversations = append(conversations, c)
+		workspaces = append(workspaces, *ws)
 	}
-	return conversations, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: list workspaces rows: %w", err)
+	}
+	return workspaces, nil
 }
 
-func (p *PostgresClient) DeleteConversation(ctx context.Context, tenantID, conversationID uuid.UUID) error {
+// UpdateWorkspace stores the name, sharing and state of ws if the stored
+// version still equals expectedVersion. On success ws.Version, ws.UpdatedAt
+// and the columns it does not change are set from the stored workspace;
+// ErrVersionConflict is returned when another update came first.
+func (p *PostgresClient) UpdateWorkspace(ctx context.Context, ws *domain.Workspace, expectedVersion int) error {
+	err := p.pool.QueryRow(ctx, `
+		UPDATE investigation_workspaces
+		SET name = $4, sharing = $5, schema_version = $6, state = $7,
+			version = version + 1, updated_by = $8, updated_at = NOW()
+		WHERE id = $1 AND job_id = $2 AND tenant_id = $3 AND version = $9
+		RETURNING owner_id, version, created_at, updated_at
+	`, ws.ID, ws.JobID, ws.TenantID, ws.Name, ws.Sharing, ws.SchemaVersion, []byte(ws.State), ws.UpdatedBy, expectedVersion).
+		Scan(&ws.OwnerID, &ws.Version, &ws.CreatedAt, &ws.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrVersionConflict
+		}
+		return fmt.Errorf("postgres: update workspace: %w", err)
+	}
+	return nil
+}
+
+// DeleteWorkspace deletes a workspace of an analysis.
+func (p *PostgresClient) DeleteWorkspace(ctx context.Context, tenantID, jobID, workspaceID uuid.UUID) error {
 	tag, err := p.pool.Exec(ctx, `
-		DELETE FROM conversations
-		WHERE id = $1 AND tenant_id = $2
-	`, conversationID, tenantID)
+		DELETE FROM investigation_workspaces
+		WHERE id = $1 AND job_id = $2 AND tenant_id = $3
+	`, workspaceID, jobID, tenantID)
 	if err != nil {
-		return fmt.Errorf("postgres: delete conversation: %w", err)
+		return fmt.Errorf("postgres: delete workspace: %w", err)
 	}
 	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("postgres: conversation not found: %s", conversationID)
+		return fmt.Errorf("postgres: workspace not found: %s", workspaceID)
 	}
 	return nil
 }
 
-func (p *PostgresClient) AddMessage(ctx context.Context, m *domain.Message) error {
-	if m.ID == uuid.Nil {
-		m.ID = uuid.New()
+// RecordUsageEvents records usage events and adds their amounts to the
+// monthly counters of their tenants. Events already recorded for the same
+// tenant, metric and source are skipped, so retried operations count once.
+// It returns the number of events newly recorded.
+func (p *PostgresClient) RecordUsageEvents(ctx context.Context, events []domain.UsageEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
 	}
-	m.CreatedAt = time.Now().UTC()
-	if m.Status == "" {
-		m.Status = domain.MessageStatusPending
+
+	// The counter is only incremented when the ledger insert happened,
+	// within the same statement.
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(`
+			WITH ev AS (
+				INSERT INTO usage_events (tenant_id, metric, source_id, amount, occurred_at)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (tenant_id, metric, source_id) DO NOTHING
+				RETURNING tenant_id, metric, amount, occurred_at
+			)
+			INSERT INTO tenant_usage (tenant_id, month, metric, value)
+			SELECT tenant_id, date_trunc('month', occurred_at AT TIME ZONE 'UTC')::date, metric, amount
+			FROM ev
+			ON CONFLICT (tenant_id, month, metric) DO UPDATE
+			SET value = tenant_usage.value + EXCLUDED.value, updated_at = NOW()
+		`, e.TenantID, e.Metric, e.SourceID, e.Amount, e.OccurredAt.UTC())
 	}
 
-	_, err := p.pool.Exec(ctx, `
-		INSERT INTO messages (id, conversation_id, tenant_id, role, content, skill_name, follow_ups, tokens_used, latency_ms, status, error_message, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`, m.ID, m.ConversationID, m.TenantID, m.Role, m.Content,
-		m.SkillName, m.FollowUps, m.TokensUsed, m.LatencyMS,
-		m.Status, m.ErrorMessage, m.CreatedAt)
-	if err != nil {
-		return fmt.Errorf("postgres: add message: %w", err)
+	br := p.pool.SendBatch(ctx, batch)
+	defer br.Close()
+	recorded := 0
+	for range events {
+		tag, err := br.Exec()
+		if err != nil {
+			return recorded, fmt.Errorf("postgres: record usage event: %w", err)
+		}
+		recorded += int(tag.RowsAffected())
 	}
-	return nil
+	return recorded, nil
 }
 
-func (p *PostgresClient) GetMessages(ctx context.Context, tenantID, conversationID uuid.UUID, limit int) ([]domain.Message, error) {
-	if limit <= 0 {
-		limit = 50
+// ListUsageEvents returns the usage events of every tenant that occurred at
+// or after since.
+func (p *PostgresClient) ListUsageEvents(ctx context.Context, since time.Time) ([]domain.UsageEvent, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT tenant_id, metric, source_id, amount, occurred_at
+		FROM usage_events
+		WHERE occurred_at >= $1
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: list usage events: %w", err)
 	}
+	return scanUsageEvents(rows)
+}
 
-	rows, err := p.pool.Query(ctx, `
-		SELECT id, conversation_id, tenant_id, role, content, skill_name, follow_ups, tokens_used, latency_ms, status, error_message, created_at
+// ListUsageSources derives the usage events of every tenant at or after
+// since from the tables that are the source of truth for them: uploaded
+// files, completed jobs, JAR run times and completed AI answers. Rows
+// stored are only known to ClickHouse and are not included.
+func (p *PostgresClient) ListUsageSources(ctx context.Context, since time.Time) ([]domain.UsageEvent, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT tenant_id, 'bytes_uploaded', id, size_bytes, uploaded_at
+		FROM log_files
+		WHERE uploaded_at >= $1
+		UNION ALL
+		SELECT tenant_id, 'analyses_run', id, 1, completed_at
+		FROM analysis_jobs
+		WHERE status = 'complete' AND completed_at >= $1
+		UNION ALL
+		SELECT tenant_id, 'worker_ms', id, wall_time_ms, COALESCE(completed_at, updated_at)
+		FROM analysis_jobs
+		WHERE wall_time_ms IS NOT NULL AND COALESCE(completed_at, updated_at) >= $1
+		UNION ALL
+		SELECT tenant_id, 'ai_queries', id, 1, created_at
 		FROM messages
-		WHERE conversation_id = $1 AND tenant_id = $2
-		ORDER BY created_at ASC
-		LIMIT $3
-	`, conversationID, tenantID, limit)
+		WHERE role = 'assistant' AND status = 'complete' AND created_at >= $1
+	`, since)
 	if err != nil {
-		return nil, fmt.Errorf("postgres: get messages: %w", err)
+		return nil, fmt.Errorf("postgres: list usage sources: %w", err)
 	}
-	defer rows.Close()
+	return scanUsageEvents(rows)
+}
 
-	var messages []domain.Message
+func scanUsageEvents(rows pgx.Rows) ([]domain.UsageEvent, error) {
+	defer rows.Close()
+	var events []domain.UsageEvent
 	for rows.Next() {
-		var m domain.Message
-		if err := rows.Scan(
-			&m.ID, &m.ConversationID, &m.TenantID, &m.Role, &m.Content,
-			&m.SkillName, &m.FollowUps, &m.TokensUsed, &m.LatencyMS,
-			&m.Status, &m.ErrorMessage, &m.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("postgres: scan message: %w", err)
+		var e domain.UsageEvent
+		if err := rows.Scan(&e.TenantID, &e.Metric, &e.SourceID, &e.Amount, &e.OccurredAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan usage event: %w", err)
 		}
-		messages = append(messages, m)
+		events = append(events, e)
 	}
-	return messages, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: usage event rows: %w", err)
+	}
+	return events, nil
 }
 
-func (p *PostgresClient) UpdateMessageStatus(ctx context.Context, tenantID, messageID uuid.UUID, status domain.MessageStatus, errorMessage string) error {
-	tag, err := p.pool.Exec(ctx, `
-		UPDATE messages
-		SET status = $1, error_message = $2
-		WHERE id = $3 AND tenant_id = $4
-	`, status, errorMessage, messageID, tenantID)
+// GetTenantUsage returns the monthly usage of a tenant, or the sum over all
+// tenants when tenantID is nil, for the calendar months from through to
+// (inclusive, UTC). Every month in the range is present. The usage of
+// sandbox tenants is left out unless includeSandbox is set.
+func (p *PostgresClient) GetTenantUsage(ctx context.Context, tenantID *uuid.UUID, from, to time.Time, includeSandbox bool) ([]domain.UsageMonth, error) {
+	months := usageMonths(from, to)
+	if len(months) == 0 {
+		return months, nil
+	}
+
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT to_char(month, 'YYYY-MM'), metric, SUM(value)
+		FROM tenant_usage
+		WHERE ($1::uuid IS NULL OR tenant_id = $1) AND month BETWEEN $2 AND $3
+			AND ($4 OR tenant_id NOT IN (SELECT id FROM tenants WHERE sandbox))
+		GROUP BY month, metric
+	`, tenantID, monthStart(from), monthStart(to), includeSandbox)
 	if err != nil {
-		return fmt.Errorf("postgres: update message status: %w", err)
+		return nil, fmt.Errorf("postgres: get tenant usage: %w", err)
 	}
-	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("postgres: message not found: %s", messageID)
+	defer rows.Close()
+
+	index := make(map[string]int, len(months))
+	for i, m := range months {
+		index[m.Month] = i
 	}
-	return nil
+	for rows.Next() {
+		var month string
+		var metric domain.UsageMetric
+		var value int64
+		if err := rows.Scan(&month, &metric, &value); err != nil {
+			return nil, fmt.Errorf("postgres: scan tenant usage: %w", err)
+		}
+		if i, ok := index[month]; ok {
+			addUsage(&months[i].UsageTotals, metric, value)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: tenant usage rows: %w", err)
+	}
+	return months, nil
 }
 
-func (p *PostgresClient) UpdateMessageContent(ctx context.Context, tenantID, messageID uuid.UUID, content string, tokensUsed, latencyMS int, status domain.MessageStatus, followUps []string) error {
-	tag, err := p.pool.Exec(ctx, `
-		UPDATE messages
-		SET content = $1, tokens_used = $2, latency_ms = $3, status = $4, follow_ups = $5
-		WHERE id = $6 AND tenant_id = $7
-	`, content, tokensUsed, latencyMS, status, followUps, messageID, tenantID)
-	if err != nil {
-		return fmt.Errorf("postgres: update message content: %w", err)
+// monthStart returns the first instant of t's calendar month in UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// usageMonths returns a zero UsageMonth for every calendar month from
+// through to, oldest first.
+func usageMonths(from, to time.Time) []domain.UsageMonth {
+	months := []domain.UsageMonth{}
+	for m := monthStart(from); !m.After(monthStart(to)); m = m.AddDate(0, 1, 0) {
+		months = append(months, domain.UsageMonth{Month: m.Format("2006-01")})
 	}
-	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("postgres: message not found: %s", messageID)
+	return months
+}
+
+// addUsage adds value to the total of metric. Unknown metrics are ignored.
+func addUsage(t *domain.UsageTotals, metric domain.UsageMetric, value int64) {
+	switch metric {
+	case domain.UsageBytesUploaded:
+		t.BytesUploaded += value
+	case domain.UsageAnalysesRun:
+		t.AnalysesRun += value
+	case domain.UsageRowsStored:
+		t.RowsStored += value
+	case domain.UsageWorkerMS:
+		t.WorkerMS += value
+	case domain.UsageAIQueries:
+		t.AIQueries += value
 	}
-	return nil
 }
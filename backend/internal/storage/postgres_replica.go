@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgReader runs the statements of the read-only methods: the pool of the
+// primary, or a replicaReader.
+type pgReader interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// pgReplica is the pool of a read replica.
+type pgReplica interface {
+	pgReader
+	Close()
+}
+
+// reader returns where the statements of a read-only method of ctx run:
+// the read replica while it is healthy and recent enough, unless ctx is
+// pinned to the primary.
+func (p *PostgresClient) reader(ctx context.Context) pgReader {
+	if p.replica == nil || UsesPrimary(ctx) || !p.replicaHealth.usable() {
+		return p.pool
+	}
+	return &replicaReader{primary: p.pool, replica: p.replica, health: p.replicaHealth}
+}
+
+// replicaReader runs statements on the replica, retrying on the primary
+// those the replica failed.
+type replicaReader struct {
+	primary pgReader
+	replica pgReader
+	health  *replicaHealth
+}
+
+// fellBack records the outcome of a replica statement and reports whether
+// it must be retried on the primary.
+func (r *replicaReader) fellBack(ctx context.Context, err error) bool {
+	if replicaFailed(ctx, err, isPostgresQueryError) {
+		r.health.failure(err)
+		return true
+	}
+	if err == nil {
+		r.health.success()
+	}
+	return false
+}
+
+func (r *replicaReader) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := r.replica.Query(ctx, sql, args...)
+	if r.fellBack(ctx, err) {
+		return r.primary.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+func (r *replicaReader) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &replicaRow{ctx: ctx, reader: r, row: r.replica.QueryRow(ctx, sql, args...), sql: sql, args: args}
+}
+
+// replicaRow is a row of the replica, read again from the primary when the
+// replica fails it: the error of QueryRow only shows on Scan.
+type replicaRow struct {
+	ctx    context.Context
+	reader *replicaReader
+	row    pgx.Row
+	sql    string
+	args   []any
+}
+
+func (r *replicaRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if r.reader.fellBack(r.ctx, err) {
+		return r.reader.primary.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return err
+}
+
+// isPostgresQueryError reports whether err is an error the primary would
+// return as well: a missing row, or an error raised by the server for the
+// statement. A replica cancelling the statement on a conflict with
+// recovery, shutting down or losing its connection is not one.
+func isPostgresQueryError(err error) bool {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch {
+	case pgErr.Code == "40001": // serialization_failure: conflict with recovery
+		return false
+	case len(pgErr.Code) == 5 && (pgErr.Code[:2] == "57" || pgErr.Code[:2] == "08"):
+		return false // operator intervention, connection exception
+	}
+	return true
+}
+
+// replicaLagQuery measures how far the replica is behind the primary. A
+// replica streaming from the primary that replayed all it received is
+// current; otherwise it is as old as the last transaction it replayed.
+// The status of the WAL receiver is hidden from roles without
+// pg_read_all_stats, which then always get the latter.
+const replicaLagQuery = `
+	SELECT (CASE
+		WHEN NOT pg_is_in_recovery() THEN 0
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn()
+			AND EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming') THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END)::float8`
+
+// SetReadReplica connects to a read replica, which the read-only methods
+// then query while it is healthy and no more than the configured
+// staleness behind. It must be called before the client is used.
+func (p *PostgresClient) SetReadReplica(ctx context.Context, dsn string, cfg ReplicaConfig) error {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return fmt.Errorf("postgres: replica: parse config: %w", err)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return fmt.Errorf("postgres: replica: connect: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return fmt.Errorf("postgres: replica: ping: %w", err)
+	}
+
+	p.replica = pool
+	p.replicaHealth = newReplicaHealth(cfg, true)
+	p.replicaHealth.checked(p.replicaLag(ctx))
+	return nil
+}
+
+// replicaLag measures the replication lag of the read replica.
+func (p *PostgresClient) replicaLag(ctx context.Context) (time.Duration, error) {
+	var seconds *float64
+	if err := p.replica.QueryRow(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("postgres: replica lag: %w", err)
+	}
+	if seconds == nil {
+		return 0, errors.New("postgres: replica lag: nothing replayed yet")
+	}
+	return time.Duration(*seconds * float64(time.Second)), nil
+}
+
+// WatchReadReplica measures the lag of the read replica at the interval of
+// its configuration until ctx is done. Reads stop going to the replica
+// when it fails, or when it may have fallen further behind than the
+// configured staleness since the last measure.
+func (p *PostgresClient) WatchReadReplica(ctx context.Context) {
+	if p.replica == nil {
+		return
+	}
+	watchReplica(ctx, p.replicaHealth, p.replicaLag)
+}
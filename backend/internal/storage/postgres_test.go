@@ -6,6 +6,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -577,3 +578,363 @@ func TestPostgres_DeleteConversation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not found")
 }
+
+// --------------------------------------------------------------------------
+// Usage accounting
+// --------------------------------------------------------------------------
+
+func TestPostgres_RecordUsageEvents_Idempotent(t *testing.T) {
+	client := setupPostgres(t)
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ClerkOrgID:     "clerk_org_usage_" + uuid.New().String()[:8],
+		Name:           "Usage Test Org",
+		Plan:           "pro",
+		StorageLimitGB: 10,
+	}
+	require.NoError(t, client.CreateTenant(ctx, tenant))
+
+	sep := time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC)
+	oct := time.Date(2026, 10, 2, 8, 0, 0, 0, time.UTC)
+	job := uuid.New()
+	events := []domain.UsageEvent{
+		{TenantID: tenant.ID, Metric: domain.UsageBytesUploaded, SourceID: uuid.New(), Amount: 4096, OccurredAt: sep},
+		{TenantID: tenant.ID, Metric: domain.UsageAnalysesRun, SourceID: job, Amount: 1, OccurredAt: oct},
+		{TenantID: tenant.ID, Metric: domain.UsageWorkerMS, SourceID: job, Amount: 5300, OccurredAt: oct},
+	}
+
+	n, err := client.RecordUsageEvents(ctx, events)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// A retried operation records the same events again.
+	n, err = client.RecordUsageEvents(ctx, events)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	months, err := client.GetTenantUsage(ctx, &tenant.ID, sep, oct, false)
+	require.NoError(t, err)
+	require.Len(t, months, 2)
+	assert.Equal(t, domain.UsageMonth{Month: "2026-09", UsageTotals: domain.UsageTotals{BytesUploaded: 4096}}, months[0])
+	assert.Equal(t, domain.UsageMonth{Month: "2026-10", UsageTotals: domain.UsageTotals{AnalysesRun: 1, WorkerMS: 5300}}, months[1])
+
+	recorded, err := client.ListUsageEvents(ctx, sep)
+	require.NoError(t, err)
+	var mine int
+	for _, e := range recorded {
+		if e.TenantID == tenant.ID {
+			mine++
+		}
+	}
+	assert.Equal(t, 3, mine)
+}
+
+// --------------------------------------------------------------------------
+// Idempotency keys
+// --------------------------------------------------------------------------
+
+func TestPostgres_IdempotencyKeys(t *testing.T) {
+	client := setupPostgres(t)
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ClerkOrgID:     "clerk_org_idem_" + uuid.New().String()[:8],
+		Name:           "Idempotency Test Org",
+		Plan:           "pro",
+		StorageLimitGB: 10,
+	}
+	require.NoError(t, client.CreateTenant(ctx, tenant))
+
+	now := time.Now().UTC()
+	claim := func(at time.Time, hash string) *domain.IdempotencyRecord {
+		return &domain.IdempotencyRecord{
+			TenantID: tenant.ID, Endpoint: "POST /api/v1/analysis", Key: "k1", RequestHash: hash,
+			CreatedAt: at, LockedUntil: at.Add(time.Minute), ExpiresAt: at.Add(time.Hour),
+		}
+	}
+
+	first := claim(now, "h1")
+	stored, claimed, err := client.BeginIdempotentRequest(ctx, first)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	assert.Nil(t, stored)
+
+	// A duplicate sees the first request in flight.
+	stored, claimed, err = client.BeginIdempotentRequest(ctx, claim(now.Add(time.Second), "h1"))
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Zero(t, stored.StatusCode)
+
+	first.StatusCode, first.ContentType, first.ResponseBody = 201, "application/json", []byte(`{"id":1}`)
+	require.NoError(t, client.CompleteIdempotentRequest(ctx, first))
+	stored, claimed, err = client.BeginIdempotentRequest(ctx, claim(now.Add(2*time.Second), "h1"))
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, 201, stored.StatusCode)
+	assert.Equal(t, `{"id":1}`, string(stored.ResponseBody))
+
+	// Once expired, the key is claimed again and then deleted.
+	later := now.Add(2 * time.Hour)
+	_, claimed, err = client.BeginIdempotentRequest(ctx, claim(later, "h2"))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	n, err := client.DeleteExpiredIdempotencyKeys(ctx, later.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+
+	// A released claim frees the key.
+	second := claim(now, "h3")
+	second.Key = "k2"
+	_, claimed, err = client.BeginIdempotentRequest(ctx, second)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, client.ReleaseIdempotentRequest(ctx, second))
+	_, claimed, err = client.BeginIdempotentRequest(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+// --------------------------------------------------------------------------
+// Tenant vocabulary
+// --------------------------------------------------------------------------
+
+func TestPostgres_Vocabulary(t *testing.T) {
+	client := setupPostgres(t)
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ClerkOrgID:     "clerk_org_vocab_" + uuid.New().String()[:8],
+		Name:           "Vocabulary Test Org",
+		Plan:           "pro",
+		StorageLimitGB: 10,
+	}
+	require.NoError(t, client.CreateTenant(ctx, tenant))
+
+	first, second := uuid.New(), uuid.New()
+	n, err := client.UpsertVocabulary(ctx, tenant.ID, first, "form", []domain.AutocompleteValue{
+		{Value: "HPD:Help Desk", Count: 120},
+		{Value: "HPD:WorkLog", Count: 30},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// A retried job does not count its values twice.
+	_, err = client.UpsertVocabulary(ctx, tenant.ID, first, "form", []domain.AutocompleteValue{{Value: "HPD:Help Desk", Count: 120}})
+	require.NoError(t, err)
+
+	_, err = client.UpsertVocabulary(ctx, tenant.ID, second, "form", []domain.AutocompleteValue{
+		{Value: "HPD:Help Desk", Count: 80},
+		{Value: "CHG:Infrastructure Change", Count: 5},
+	})
+	require.NoError(t, err)
+
+	terms, err := client.SearchVocabulary(ctx, tenant.ID, "form", "hpd", true, 10)
+	require.NoError(t, err)
+	require.Len(t, terms, 2)
+	assert.Equal(t, "HPD:Help Desk", terms[0].Value)
+	assert.Equal(t, int64(200), terms[0].Occurrences)
+	assert.Equal(t, int64(2), terms[0].JobCount)
+	assert.Equal(t, second, terms[0].LastJobID)
+
+	terms, err = client.SearchVocabulary(ctx, tenant.ID, "form", "desk", false, 10)
+	require.NoError(t, err)
+	require.Len(t, terms, 1)
+
+	// Only the two most recently seen forms are kept.
+	evicted, err := client.EvictVocabulary(ctx, tenant.ID, time.Now().Add(-time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), evicted)
+	terms, err = client.SearchVocabulary(ctx, tenant.ID, "form", "", false, 10)
+	require.NoError(t, err)
+	require.Len(t, terms, 2)
+	assert.Equal(t, "HPD:Help Desk", terms[0].Value)
+	assert.Equal(t, "CHG:Infrastructure Change", terms[1].Value)
+
+	// Values unseen since the cutoff are evicted.
+	evicted, err = client.EvictVocabulary(ctx, tenant.ID, time.Now().Add(time.Hour), 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), evicted)
+}
+
+func TestPostgres_JobEvents(t *testing.T) {
+	client := setupPostgres(t)
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ClerkOrgID:     "clerk_org_events_" + uuid.New().String()[:8],
+		Name:           "Job Events Test Org",
+		Plan:           "pro",
+		StorageLimitGB: 10,
+	}
+	require.NoError(t, client.CreateTenant(ctx, tenant))
+	logFile := &domain.LogFile{TenantID: tenant.ID, Filename: "server.log", SizeBytes: 1024,
+		S3Key: "test/events.log", S3Bucket: "remedyiq-logs", ContentType: "text/plain"}
+	require.NoError(t, client.CreateLogFile(ctx, logFile))
+	job := &domain.AnalysisJob{TenantID: tenant.ID, Status: domain.JobStatusQueued, FileID: logFile.ID, JVMHeapMB: 4096, TimeoutSeconds: 1800}
+	require.NoError(t, client.CreateJob(ctx, job))
+
+	at := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	ev := func(kind domain.JobEventKind, stage string, offset time.Duration) domain.JobEvent {
+		return domain.JobEvent{TenantID: tenant.ID, JobID: job.ID, Source: domain.JobEventSourceWorker,
+			Kind: kind, Stage: stage, OccurredAt: at.Add(offset)}
+	}
+	n, err := client.AppendJobEvents(ctx, []domain.JobEvent{
+		ev(domain.JobEventStageStarted, "jar", time.Second),
+		ev(domain.JobEventCreated, "", 0),
+		ev(domain.JobEventStageFinished, "jar", time.Second),
+		// A job that no longer exists is skipped without failing the batch.
+		{TenantID: tenant.ID, JobID: uuid.New(), Source: domain.JobEventSourceWorker, Kind: domain.JobEventProgress, OccurredAt: at},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	events, err := client.ListJobEvents(ctx, tenant.ID, job.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, domain.JobEventCreated, events[0].Kind)
+	assert.Equal(t, domain.JobEventStageStarted, events[1].Kind, "same-instant events keep their write order")
+	assert.Equal(t, domain.JobEventStageFinished, events[2].Kind)
+
+	_, err = client.PurgeJob(ctx, tenant.ID, job.ID)
+	require.NoError(t, err)
+	events, err = client.ListJobEvents(ctx, tenant.ID, job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, events, "events are purged with the analysis")
+}
+
+func TestPostgres_JobCancellation(t *testing.T) {
+	client := setupPostgres(t)
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ClerkOrgID:     "clerk_org_cancel_" + uuid.New().String()[:8],
+		Name:           "Job Cancellation Test Org",
+		Plan:           "pro",
+		StorageLimitGB: 10,
+	}
+	require.NoError(t, client.CreateTenant(ctx, tenant))
+	logFile := &domain.LogFile{TenantID: tenant.ID, Filename: "server.log", SizeBytes: 1024,
+		S3Key: "test/cancel.log", S3Bucket: "remedyiq-logs", ContentType: "text/plain"}
+	require.NoError(t, client.CreateLogFile(ctx, logFile))
+	newJob := func() *domain.AnalysisJob {
+		job := &domain.AnalysisJob{TenantID: tenant.ID, Status: domain.JobStatusQueued, FileID: logFile.ID, JVMHeapMB: 4096, TimeoutSeconds: 1800}
+		require.NoError(t, client.CreateJob(ctx, job))
+		return job
+	}
+
+	t.Run("queued job is cancelled at once", func(t *testing.T) {
+		job := newJob()
+		got, requested, err := client.RequestJobCancel(ctx, tenant.ID, job.ID)
+		require.NoError(t, err)
+		assert.True(t, requested)
+		assert.Equal(t, domain.JobStatusCancelled, got.Status)
+		assert.NotNil(t, got.CancelRequestedAt)
+		assert.NotNil(t, got.CompletedAt)
+
+		err = client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusParsing, nil)
+		assert.ErrorIs(t, err, ErrJobCancelled, "a worker receiving the job skips it")
+
+		got, requested, err = client.RequestJobCancel(ctx, tenant.ID, job.ID)
+		require.NoError(t, err)
+		assert.False(t, requested)
+		assert.Equal(t, domain.JobStatusCancelled, got.Status)
+	})
+
+	t.Run("running job can only end cancelled", func(t *testing.T) {
+		job := newJob()
+		require.NoError(t, client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusParsing, nil))
+		got, requested, err := client.RequestJobCancel(ctx, tenant.ID, job.ID)
+		require.NoError(t, err)
+		assert.True(t, requested)
+		assert.Equal(t, domain.JobStatusParsing, got.Status, "the worker stops a running job")
+
+		assert.ErrorIs(t, client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusStoring, nil), ErrJobCancelled)
+		assert.ErrorIs(t, client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusComplete, nil), ErrJobCancelled)
+		errMsg := "analysis cancelled"
+		require.NoError(t, client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusCancelled, &errMsg))
+		assert.ErrorIs(t, client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusCancelled, &errMsg), ErrJobCancelled)
+
+		fetched, err := client.GetJob(ctx, tenant.ID, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.JobStatusCancelled, fetched.Status)
+		assert.NotNil(t, fetched.CompletedAt)
+	})
+
+	t.Run("completed job is not cancelled", func(t *testing.T) {
+		job := newJob()
+		require.NoError(t, client.UpdateJobStatus(ctx, tenant.ID, job.ID, domain.JobStatusComplete, nil))
+		got, requested, err := client.RequestJobCancel(ctx, tenant.ID, job.ID)
+		require.NoError(t, err)
+		assert.False(t, requested)
+		assert.Equal(t, domain.JobStatusComplete, got.Status)
+		assert.Nil(t, got.CancelRequestedAt)
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		_, _, err := client.RequestJobCancel(ctx, tenant.ID, uuid.New())
+		assert.True(t, IsNotFound(err))
+	})
+}
+
+// --------------------------------------------------------------------------
+// Background tasks
+// --------------------------------------------------------------------------
+
+func TestPostgres_BackgroundTasks(t *testing.T) {
+	client := setupPostgres(t)
+	ctx := context.Background()
+
+	name := "test-task-" + uuid.New().String()[:8]
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, client.RegisterBackgroundTask(ctx, &domain.BackgroundTask{
+		Name: name, Schedule: "@every 1h0m0s", TimeoutSeconds: 60, NextRunAt: now.Add(-time.Second),
+	}))
+
+	// Two workers race for the due task: one claims it.
+	_, claimedA, err := client.ClaimBackgroundTask(ctx, name, "worker-a", now, now.Add(2*time.Minute), now.Add(time.Hour))
+	require.NoError(t, err)
+	_, claimedB, err := client.ClaimBackgroundTask(ctx, name, "worker-b", now, now.Add(2*time.Minute), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, claimedA)
+	assert.False(t, claimedB)
+
+	// Run-now during the run waits for it.
+	task, err := client.RequestBackgroundTaskRun(ctx, name, "admin")
+	require.NoError(t, err)
+	require.NotNil(t, task.RunRequestedAt)
+	due, err := client.DueBackgroundTasks(ctx, now)
+	require.NoError(t, err)
+	assert.NotContains(t, due, name)
+
+	run := &domain.BackgroundTaskRun{
+		TaskName: name, Trigger: domain.TaskTriggerSchedule, WorkerID: "worker-a",
+		StartedAt: now, FinishedAt: now.Add(time.Second), DurationMS: 1000, Outcome: domain.TaskOutcomeSuccess,
+	}
+	require.NoError(t, client.FinishBackgroundTaskRun(ctx, run))
+	assert.NotZero(t, run.ID)
+
+	trigger, claimedB, err := client.ClaimBackgroundTask(ctx, name, "worker-b", now, now.Add(2*time.Minute), now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, claimedB)
+	assert.Equal(t, domain.TaskTriggerManual, trigger)
+
+	tasks, err := client.ListBackgroundTasks(ctx)
+	require.NoError(t, err)
+	var found *domain.BackgroundTask
+	for i := range tasks {
+		if tasks[i].Name == name {
+			found = &tasks[i]
+		}
+	}
+	require.NotNil(t, found)
+	assert.True(t, found.Running)
+	assert.Equal(t, "worker-b", found.LockedBy)
+	assert.Nil(t, found.RunRequestedAt)
+	assert.True(t, found.NextRunAt.Equal(now.Add(time.Hour)), "a requested run leaves the schedule alone")
+	require.NotNil(t, found.LastRun)
+	assert.Equal(t, run.ID, found.LastRun.ID)
+
+	_, err = client.RequestBackgroundTaskRun(ctx, "no-such-task", "admin")
+	assert.True(t, IsNotFound(err))
+}
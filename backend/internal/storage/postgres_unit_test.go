@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -296,3 +299,46 @@ func TestIsNotFound_NonMatchingPackageErrors(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Usage month helpers
+// ---------------------------------------------------------------------------
+
+func TestUsageMonths(t *testing.T) {
+	from := time.Date(2025, 11, 20, 8, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	var got []string
+	for _, m := range usageMonths(from, to) {
+		got = append(got, m.Month)
+		assert.Zero(t, m.UsageTotals)
+	}
+	assert.Equal(t, []string{"2025-11", "2025-12", "2026-01", "2026-02"}, got)
+	assert.Len(t, usageMonths(to, to), 1)
+	assert.Empty(t, usageMonths(to, from))
+}
+
+func TestMonthStart_UsesUTC(t *testing.T) {
+	// 00:30 on March 1st in UTC+2 is still February in UTC.
+	local := time.Date(2026, 3, 1, 0, 30, 0, 0, time.FixedZone("EET", 2*60*60))
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), monthStart(local))
+}
+
+func TestAddUsage(t *testing.T) {
+	var totals domain.UsageTotals
+	addUsage(&totals, domain.UsageBytesUploaded, 100)
+	addUsage(&totals, domain.UsageBytesUploaded, 50)
+	addUsage(&totals, domain.UsageAnalysesRun, 2)
+	addUsage(&totals, domain.UsageRowsStored, 9000)
+	addUsage(&totals, domain.UsageWorkerMS, 1500)
+	addUsage(&totals, domain.UsageAIQueries, 3)
+	addUsage(&totals, domain.UsageMetric("unknown"), 7)
+
+	assert.Equal(t, domain.UsageTotals{
+		BytesUploaded: 150,
+		AnalysesRun:   2,
+		RowsStored:    9000,
+		WorkerMS:      1500,
+		AIQueries:     3,
+	}, totals)
+}
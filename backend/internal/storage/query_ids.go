@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+type queryIDKey struct{}
+
+// queryTag carries the query ID of an API request. A request may run
+// several ClickHouse statements, so each gets the ID with a sequence number
+// appended.
+type queryTag struct {
+	id  string
+	seq atomic.Int64
+}
+
+// WithQueryID returns a context whose ClickHouse statements are sent with
+// query IDs of the form "<id>:<n>", so that they can be listed and killed
+// together with KillQuery.
+func WithQueryID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, queryIDKey{}, &queryTag{id: id})
+}
+
+// QueryIDFrom returns the query ID set by WithQueryID, or "".
+func QueryIDFrom(ctx context.Context) string {
+	if tag, ok := ctx.Value(queryIDKey{}).(*queryTag); ok && tag != nil {
+		return tag.id
+	}
+	return ""
+}
+
+// nextQueryID returns the ClickHouse query ID of the next statement of the
+// request, if it has a query ID.
+func nextQueryID(ctx context.Context) (string, bool) {
+	tag, ok := ctx.Value(queryIDKey{}).(*queryTag)
+	if !ok || tag == nil {
+		return "", false
+	}
+	return tag.id + ":" + strconv.FormatInt(tag.seq.Add(1), 10), true
+}
+
+// tagQuery attaches the next query ID of the request, if any, to ctx.
+func tagQuery(ctx context.Context) context.Context {
+	id, ok := nextQueryID(ctx)
+	if !ok {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithQueryID(id))
+}
+
+// taggedConn is a driver.Conn that sends the request query ID with every
+// statement.
+type taggedConn struct {
+	driver.Conn
+}
+
+func (c taggedConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	return c.Conn.Query(tagQuery(ctx), query, args...)
+}
+
+func (c taggedConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return c.Conn.QueryRow(tagQuery(ctx), query, args...)
+}
+
+func (c taggedConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return c.Conn.Select(tagQuery(ctx), dest, query, args...)
+}
+
+func (c taggedConn) Exec(ctx context.Context, query string, args ...any) error {
+	return c.Conn.Exec(tagQuery(ctx), query, args...)
+}
+
+// KillQuery asks ClickHouse to cancel the statements sent with query ID id.
+// The kill is asynchronous: statements may still be finishing when it
+// returns. It is not itself tagged, and still runs when ctx is cancelled
+// so that it can be used on client disconnect.
+func (c *ClickHouseClient) KillQuery(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("clickhouse: kill query: empty query id")
+	}
+	ctx = context.WithValue(context.WithoutCancel(ctx), queryIDKey{}, (*queryTag)(nil))
+	const kill = "KILL QUERY WHERE startsWith(query_id, @prefix) ASYNC"
+	err := c.conn.Exec(ctx, kill, clickhouse.Named("prefix", id+":"))
+	if err != nil {
+		return fmt.Errorf("clickhouse: kill query %s: %w", id, err)
+	}
+	if err := c.killOnReplica(ctx, kill, clickhouse.Named("prefix", id+":")); err != nil {
+		return fmt.Errorf("clickhouse: kill query %s on replica: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextQueryID(t *testing.T) {
+	_, ok := nextQueryID(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, "", QueryIDFrom(context.Background()))
+
+	ctx := WithQueryID(context.Background(), "q1")
+	assert.Equal(t, "q1", QueryIDFrom(ctx))
+	for _, want := range []string{"q1:1", "q1:2", "q1:3"} {
+		id, ok := nextQueryID(ctx)
+		require.True(t, ok)
+		assert.Equal(t, want, id, "each statement of a request gets its own query ID")
+	}
+}
+
+func TestKillQuery(t *testing.T) {
+	conn := &fakeConn{}
+	c := &ClickHouseClient{conn: taggedConn{conn}}
+
+	// The kill runs on a cancelled request context, as on client disconnect.
+	ctx, cancel := context.WithCancel(WithQueryID(context.Background(), "q1"))
+	cancel()
+	require.NoError(t, c.KillQuery(ctx, "q1"))
+
+	require.Len(t, conn.execs, 1)
+	assert.Equal(t, "KILL QUERY WHERE startsWith(query_id, @prefix) ASYNC", conn.execs[0])
+	assert.Equal(t, []any{clickhouse.Named("prefix", "q1:")}, conn.execArgs[0])
+
+	id, ok := nextQueryID(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "q1:1", id, "the kill statement is not tagged")
+
+	assert.Error(t, c.KillQuery(context.Background(), ""))
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+//go:embed query_slots.lua
+var querySlotsLua string
+
+var querySlotsScript = redis.NewScript(querySlotsLua)
+
+// querySlotKeys returns the keys of a tenant's query semaphore: the slots
+// and the descriptions of the queries holding them.
+func (r *RedisClient) querySlotKeys(tenantID string) []string {
+	return []string{r.TenantKey(tenantID, "queries", ""), r.TenantKey(tenantID, "queries", "info")}
+}
+
+// AcquireQuerySlot takes one of limit query slots of the tenant for q,
+// reporting whether one was free. A limit of 0 or less always succeeds, so
+// that the query is still listed. The slot is held until ReleaseQuerySlot
+// or the lease runs out, whichever comes first.
+func (r *RedisClient) AcquireQuerySlot(ctx context.Context, q domain.InFlightQuery, limit int, lease time.Duration) (bool, error) {
+	if lease <= 0 {
+		return false, fmt.Errorf("redis: query slot %s: lease must be positive", q.ID)
+	}
+	info, err := json.Marshal(q)
+	if err != nil {
+		return false, fmt.Errorf("redis: query slot %s: marshal: %w", q.ID, err)
+	}
+	res, err := querySlotsScript.Run(ctx, r.client, r.querySlotKeys(q.TenantID),
+		q.ID, limit, lease.Milliseconds(), string(info)).Slice()
+	if err != nil {
+		return false, fmt.Errorf("redis: query slot %s: %w", q.ID, err)
+	}
+	if len(res) != 2 {
+		return false, fmt.Errorf("redis: query slot %s: unexpected reply %v", q.ID, res)
+	}
+	acquired, _ := res[0].(int64)
+	return acquired == 1, nil
+}
+
+// ReleaseQuerySlot frees the slot held by query id. Releasing a slot that
+// is not held is not an error.
+func (r *RedisClient) ReleaseQuerySlot(ctx context.Context, tenantID, id string) error {
+	keys := r.querySlotKeys(tenantID)
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, keys[0], id)
+	pipe.HDel(ctx, keys[1], id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis: release query slot %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListQuerySlots returns the queries holding a slot of the tenant, oldest
+// first. Expired leases are left out.
+func (r *RedisClient) ListQuerySlots(ctx context.Context, tenantID string) ([]domain.InFlightQuery, error) {
+	infos, err := r.slotHolders(ctx, r.querySlotKeys(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("redis: list query slots: %w", err)
+	}
+	queries := make([]domain.InFlightQuery, 0, len(infos))
+	for _, s := range infos {
+		var q domain.InFlightQuery
+		if err := json.Unmarshal([]byte(s), &q); err != nil {
+			continue
+		}
+		queries = append(queries, q)
+	}
+	sort.SliceStable(queries, func(i, j int) bool { return queries[i].StartedAt.Before(queries[j].StartedAt) })
+	return queries, nil
+}
+
+// slotHolders returns the descriptions of the holders of the unexpired
+// slots of a semaphore run by querySlotsScript.
+func (r *RedisClient) slotHolders(ctx context.Context, keys []string) ([]string, error) {
+	now, err := r.client.Time(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := r.client.ZRangeByScore(ctx, keys[0], &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(now.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	infos, err := r.client.HMGet(ctx, keys[1], ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	holders := make([]string, 0, len(infos))
+	for _, v := range infos {
+		if s, ok := v.(string); ok { // else released between the two reads
+			holders = append(holders, s)
+		}
+	}
+	return holders, nil
+}
@@ -0,0 +1,39 @@
+-- Concurrent query semaphore.
+--
+-- KEYS[1]  sorted set of query IDs scored by lease expiry in ms
+-- KEYS[2]  hash of query ID to query description
+-- ARGV[1]  query ID
+-- ARGV[2]  slots; 0 or less is unlimited
+-- ARGV[3]  lease in ms
+-- ARGV[4]  query description
+--
+-- Returns {acquired (0/1), slots in use}.
+--
+-- Leases that expired, left by replicas that died mid-request, are purged
+-- first. The clock is the Redis server's, as for the token buckets.
+
+local slots = KEYS[1]
+local info = KEYS[2]
+local id = ARGV[1]
+local limit = tonumber(ARGV[2])
+local lease = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local expired = redis.call('ZRANGEBYSCORE', slots, '-inf', now)
+if #expired > 0 then
+	redis.call('ZREM', slots, unpack(expired))
+	redis.call('HDEL', info, unpack(expired))
+end
+
+local used = redis.call('ZCARD', slots)
+if limit > 0 and used >= limit then
+	return {0, used}
+end
+
+redis.call('ZADD', slots, now + lease, id)
+redis.call('HSET', info, id, ARGV[4])
+redis.call('PEXPIRE', slots, lease)
+redis.call('PEXPIRE', info, lease)
+return {1, used + 1}
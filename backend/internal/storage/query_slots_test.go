@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func inFlight(id string, started time.Time) domain.InFlightQuery {
+	return domain.InFlightQuery{ID: id, TenantID: "t1", JobID: "j1", Method: "GET", Path: "/api/v1/analysis/j1/search", StartedAt: started}
+}
+
+func TestQuerySlots(t *testing.T) {
+	ctx := context.Background()
+	client, mr := newMiniredisClient(t)
+	start := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	for i, id := range []string{"q1", "q2"} {
+		ok, err := client.AcquireQuerySlot(ctx, inFlight(id, start.Add(time.Duration(i)*time.Second)), 2, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, id)
+	}
+	ok, err := client.AcquireQuerySlot(ctx, inFlight("q3", start), 2, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "both slots are held")
+
+	queries, err := client.ListQuerySlots(ctx, "t1")
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, "q1", queries[0].ID)
+	assert.Equal(t, "j1", queries[0].JobID)
+	assert.Equal(t, "q2", queries[1].ID)
+
+	require.NoError(t, client.ReleaseQuerySlot(ctx, "t1", "q1"))
+	require.NoError(t, client.ReleaseQuerySlot(ctx, "t1", "q1"), "releasing twice is harmless")
+	ok, err = client.AcquireQuerySlot(ctx, inFlight("q3", start), 2, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Leases left by a dead replica run out.
+	mr.SetTime(start.Add(2 * time.Minute))
+	queries, err = client.ListQuerySlots(ctx, "t1")
+	require.NoError(t, err)
+	assert.Empty(t, queries)
+	ok, err = client.AcquireQuerySlot(ctx, inFlight("q4", start), 1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "expired leases are purged before counting")
+
+	// Without a limit the query is only recorded.
+	for i := 0; i < 5; i++ {
+		ok, err = client.AcquireQuerySlot(ctx, inFlight(fmt.Sprintf("u%d", i), start), 0, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	other, err := client.ListQuerySlots(ctx, "t2")
+	require.NoError(t, err)
+	assert.Empty(t, other, "slots are per tenant")
+
+	_, err = client.AcquireQuerySlot(ctx, inFlight("q5", start), 1, 0)
+	assert.Error(t, err)
+}
+
+func TestQuerySlots_Parallel(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newMiniredisClient(t)
+
+	var acquired atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := client.AcquireQuerySlot(ctx, inFlight(fmt.Sprintf("q%d", i), time.Now()), 3, time.Minute)
+			if assert.NoError(t, err) && ok {
+				acquired.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(3), acquired.Load())
+
+	queries, err := client.ListQuerySlots(ctx, "t1")
+	require.NoError(t, err)
+	assert.Len(t, queries, 3)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -14,6 +15,10 @@ import (
 // and rate limiting operations.
 type RedisClient struct {
 	client *redis.Client
+	policy CachePolicy
+
+	// now is the clock of the cache usage accounting; tests replace it.
+	now func() time.Time
 }
 
 // NewRedisClient creates a new Redis client from the given URL.
@@ -32,7 +37,13 @@ func NewRedisClient(ctx context.Context, url string) (*RedisClient, error) {
 		return nil, fmt.Errorf("redis: ping: %w", err)
 	}
 
-	return &RedisClient{client: client}, nil
+	return &RedisClient{client: client, policy: DefaultCachePolicy(), now: time.Now}, nil
+}
+
+// SetCachePolicy replaces the policy applied to the tenant caches, which is
+// DefaultCachePolicy until then.
+func (r *RedisClient) SetCachePolicy(p CachePolicy) {
+	r.policy = p
 }
 
 // Close releases the underlying Redis connection.
@@ -45,36 +56,59 @@ func (r *RedisClient) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
-// Get retrieves a string value by key. Returns redis.Nil error if the key
-// does not exist; callers should check with errors.Is(err, redis.Nil).
+// Get retrieves a string value by key, decompressing it if it was stored
+// compressed. Returns redis.Nil error if the key does not exist; callers
+// should check with errors.Is(err, redis.Nil).
 func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	val, err := r.client.Get(ctx, key).Result()
 	if err != nil {
 		return "", err
 	}
-	return val, nil
+	return decodeCacheValue(val)
 }
 
 // Set stores a value in Redis with the given TTL. The value is JSON-encoded
 // if it is not already a string or []byte.
+//
+// Tenant keys are subject to the cache policy: large values are compressed
+// and kept for a shorter TTL, and a write that would take the tenant past
+// its budget is skipped and logged rather than failed, since every caller
+// can recompute what it caches.
 func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	var data interface{}
+	var payload []byte
 	switch v := value.(type) {
 	case string:
-		data = v
+		payload = []byte(v)
 	case []byte:
-		data = v
+		payload = v
 	default:
 		encoded, err := json.Marshal(v)
 		if err != nil {
 			return fmt.Errorf("redis: marshal value: %w", err)
 		}
-		data = encoded
+		payload = encoded
+	}
+
+	tenantID, namespace, isTenantKey := parseTenantKey(key)
+	if !isTenantKey {
+		if err := r.client.Set(ctx, key, payload, ttl).Err(); err != nil {
+			return fmt.Errorf("redis: set %q: %w", key, err)
+		}
+		return nil
+	}
+
+	ttl = r.policy.ttlFor(len(payload), ttl)
+	data := encodeCacheValue(payload, r.policy.CompressMinBytes)
+	if !r.admitCacheWrite(ctx, tenantID, namespace, len(data)) {
+		return nil
 	}
 
 	if err := r.client.Set(ctx, key, data, ttl).Err(); err != nil {
 		return fmt.Errorf("redis: set %q: %w", key, err)
 	}
+	if err := r.recordCacheUsage(ctx, tenantID, namespace, len(data), false); err != nil {
+		slog.Warn("failed to record cache usage", "tenant_id", tenantID, "error", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// CacheUsageWindow is how far back the bytes a tenant writes to the cache
+// count towards its usage: the longest TTL the caches are written with.
+// Older writes have expired.
+const CacheUsageWindow = 24 * time.Hour
+
+// cacheFormatZstd is the version byte of values stored zstd-compressed.
+// Values starting with any other byte are the payload as is, the format
+// written before compression: cached payloads are JSON or text, which never
+// start with a control byte.
+const cacheFormatZstd byte = 0x01
+
+// cacheUsageTenantsKey is the set of the tenants with cache usage recorded.
+const cacheUsageTenantsKey = "remedyiq:cache-usage:tenants"
+
+// CacheTTLTier caps the TTL of cached payloads of at least MinBytes.
+type CacheTTLTier struct {
+	MinBytes int
+	MaxTTL   time.Duration
+}
+
+// CachePolicy bounds the Redis memory the caches of a tenant take, so that
+// one tenant with many analyses does not evict the hot keys of the others.
+// It applies to the keys built with TenantKey.
+type CachePolicy struct {
+	// TenantBudgetBytes is the soft budget of the bytes a tenant writes
+	// within CacheUsageWindow. Writes past it are skipped, not failed.
+	// Zero disables the budget.
+	TenantBudgetBytes int64
+
+	// TTLTiers shortens the TTL of the largest payloads. The first tier a
+	// payload is large enough for applies, so tiers are listed largest
+	// first. A TTL is never lengthened.
+	TTLTiers []CacheTTLTier
+
+	// CompressMinBytes is the payload size from which values are stored
+	// compressed. Zero disables compression.
+	CompressMinBytes int
+}
+
+// DefaultCachePolicy compresses payloads of 16 KiB and more and keeps those
+// of 1 MiB and more for at most six hours, and those of 4 MiB and more for
+// at most one. It has no budget.
+func DefaultCachePolicy() CachePolicy {
+	return CachePolicy{
+		TTLTiers: []CacheTTLTier{
+			{MinBytes: 4 << 20, MaxTTL: time.Hour},
+			{MinBytes: 1 << 20, MaxTTL: 6 * time.Hour},
+		},
+		CompressMinBytes: 16 << 10,
+	}
+}
+
+// ttlFor returns the TTL of a payload of size bytes requested with ttl.
+func (p CachePolicy) ttlFor(size int, ttl time.Duration) time.Duration {
+	for _, tier := range p.TTLTiers {
+		if size >= tier.MinBytes {
+			if ttl <= 0 || ttl > tier.MaxTTL {
+				return tier.MaxTTL
+			}
+			return ttl
+		}
+	}
+	return ttl
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// encodeCacheValue returns the value stored for payload: compressed behind
+// its version byte from minBytes on, as is below.
+func encodeCacheValue(payload []byte, minBytes int) []byte {
+	if minBytes <= 0 || len(payload) < minBytes {
+		return payload
+	}
+	out := make([]byte, 1, 1+len(payload)/4)
+	out[0] = cacheFormatZstd
+	return zstdEncoder.EncodeAll(payload, out)
+}
+
+// decodeCacheValue returns the payload of a stored value, in either format.
+func decodeCacheValue(stored string) (string, error) {
+	if len(stored) == 0 || stored[0] != cacheFormatZstd {
+		return stored, nil
+	}
+	payload, err := zstdDecoder.DecodeAll([]byte(stored[1:]), nil)
+	if err != nil {
+		return "", fmt.Errorf("redis: decompress cached value: %w", err)
+	}
+	return string(payload), nil
+}
+
+// parseTenantKey returns the tenant and the namespace, the category given
+// to TenantKey, of a tenant key. ok is false for other keys.
+func parseTenantKey(key string) (tenantID, namespace string, ok bool) {
+	parts := strings.SplitN(key, ":", 4)
+	if len(parts) < 3 || parts[0] != "remedyiq" || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// cacheUsageBuckets returns the keys of the hourly usage counters of a
+// tenant within CacheUsageWindow of now, the current hour first.
+func cacheUsageBuckets(tenantID, counter string, now time.Time) []string {
+	hours := int(CacheUsageWindow / time.Hour)
+	current := now.Unix() / 3600
+	keys := make([]string, hours)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("remedyiq:%s:%s:%d", tenantID, counter, current-int64(i))
+	}
+	return keys
+}
+
+// cacheUsage reads the bytes per namespace and the skipped writes per
+// namespace a tenant has recorded within CacheUsageWindow.
+func (r *RedisClient) cacheUsage(ctx context.Context, tenantID string) (bytes, skipped map[string]int64, err error) {
+	now := r.now()
+	byteKeys := cacheUsageBuckets(tenantID, "cache-bytes", now)
+	skipKeys := cacheUsageBuckets(tenantID, "cache-skipped", now)
+
+	pipe := r.client.Pipeline()
+	byteCmds := make([]*redis.MapStringStringCmd, len(byteKeys))
+	for i, k := range byteKeys {
+		byteCmds[i] = pipe.HGetAll(ctx, k)
+	}
+	skipCmds := make([]*redis.MapStringStringCmd, len(skipKeys))
+	for i, k := range skipKeys {
+		skipCmds[i] = pipe.HGetAll(ctx, k)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, fmt.Errorf("redis: read cache usage: %w", err)
+	}
+
+	sum := func(cmds []*redis.MapStringStringCmd) map[string]int64 {
+		out := make(map[string]int64)
+		for _, cmd := range cmds {
+			for ns, v := range cmd.Val() {
+				n, _ := strconv.ParseInt(v, 10, 64)
+				out[ns] += n
+			}
+		}
+		return out
+	}
+	return sum(byteCmds), sum(skipCmds), nil
+}
+
+// recordCacheUsage counts size bytes written to a namespace of a tenant,
+// or a skipped write when skipped is set.
+func (r *RedisClient) recordCacheUsage(ctx context.Context, tenantID, namespace string, size int, skipped bool) error {
+	counter, incr := "cache-bytes", int64(size)
+	if skipped {
+		counter, incr = "cache-skipped", 1
+	}
+	key := cacheUsageBuckets(tenantID, counter, r.now())[0]
+
+	pipe := r.client.Pipeline()
+	pipe.HIncrBy(ctx, key, namespace, incr)
+	pipe.Expire(ctx, key, CacheUsageWindow+time.Hour)
+	pipe.SAdd(ctx, cacheUsageTenantsKey, tenantID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis: record cache usage: %w", err)
+	}
+	return nil
+}
+
+// admitCacheWrite reports whether size more bytes fit in the budget of a
+// tenant. Writes are admitted when the usage cannot be read: the budget
+// protects Redis, it must not take the caches down with it.
+func (r *RedisClient) admitCacheWrite(ctx context.Context, tenantID, namespace string, size int) bool {
+	budget := r.policy.TenantBudgetBytes
+	if budget <= 0 {
+		return true
+	}
+	bytes, _, err := r.cacheUsage(ctx, tenantID)
+	if err != nil {
+		slog.Warn("cache budget check failed", "tenant_id", tenantID, "error", err)
+		return true
+	}
+	var used int64
+	for _, n := range bytes {
+		used += n
+	}
+	if used+int64(size) <= budget {
+		return true
+	}
+
+	slog.Warn("tenant cache budget exceeded, skipping cache write",
+		"tenant_id", tenantID, "namespace", namespace, "size", size, "used", used, "budget", budget)
+	if err := r.recordCacheUsage(ctx, tenantID, namespace, 0, true); err != nil {
+		slog.Warn("failed to record skipped cache write", "tenant_id", tenantID, "error", err)
+	}
+	return false
+}
+
+// TenantCacheUsage returns the cache usage of a tenant.
+func (r *RedisClient) TenantCacheUsage(ctx context.Context, tenantID string) (*domain.TenantCacheUsage, error) {
+	bytes, skipped, err := r.cacheUsage(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	u := &domain.TenantCacheUsage{
+		TenantID:    tenantID,
+		BudgetBytes: r.policy.TenantBudgetBytes,
+		Namespaces:  bytes,
+	}
+	for _, n := range bytes {
+		u.Bytes += n
+	}
+	for _, n := range skipped {
+		u.SkippedWrites += n
+	}
+	return u, nil
+}
+
+// AllTenantsCacheUsage returns the cache usage of every tenant that wrote
+// to the cache within CacheUsageWindow, largest first. Tenants without
+// recent usage are forgotten.
+func (r *RedisClient) AllTenantsCacheUsage(ctx context.Context) ([]domain.TenantCacheUsage, error) {
+	tenants, err := r.client.SMembers(ctx, cacheUsageTenantsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis: list cache tenants: %w", err)
+	}
+
+	out := make([]domain.TenantCacheUsage, 0, len(tenants))
+	for _, tenantID := range tenants {
+		u, err := r.TenantCacheUsage(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if u.Bytes == 0 && u.SkippedWrites == 0 {
+			_ = r.client.SRem(ctx, cacheUsageTenantsKey, tenantID).Err()
+			continue
+		}
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return out[i].TenantID < out[j].TenantID
+	})
+	return out, nil
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCacheTestRedis(t *testing.T, policy CachePolicy) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	r, err := NewRedisClient(context.Background(), "redis://"+mr.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+	r.SetCachePolicy(policy)
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, mr
+}
+
+func TestParseTenantKey(t *testing.T) {
+	r := &RedisClient{}
+	tenant, ns, ok := parseTenantKey(r.TenantKey("t1", "dashboard", "job-1:agg"))
+	assert.True(t, ok)
+	assert.Equal(t, "t1", tenant)
+	assert.Equal(t, "dashboard", ns)
+
+	tenant, ns, ok = parseTenantKey(r.TenantKey("t1", "trace:recent", "u1"))
+	assert.True(t, ok)
+	assert.Equal(t, "t1", tenant)
+	assert.Equal(t, "trace", ns)
+
+	for _, key := range []string{"other:t1:dashboard", "remedyiq:t1", "remedyiq::dashboard", "plain"} {
+		_, _, ok := parseTenantKey(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestCachePolicy_TTLFor(t *testing.T) {
+	p := DefaultCachePolicy()
+	assert.Equal(t, 24*time.Hour, p.ttlFor(100, 24*time.Hour))
+	assert.Equal(t, 6*time.Hour, p.ttlFor(2<<20, 24*time.Hour))
+	assert.Equal(t, time.Hour, p.ttlFor(8<<20, 24*time.Hour))
+	assert.Equal(t, 5*time.Minute, p.ttlFor(8<<20, 5*time.Minute), "a tier never lengthens a TTL")
+	assert.Equal(t, time.Hour, p.ttlFor(8<<20, 0), "a large value without TTL gets the tier's")
+}
+
+func TestRedisCache_CompressedRoundTrip(t *testing.T) {
+	r, mr := newCacheTestRedis(t, CachePolicy{CompressMinBytes: 1024})
+	ctx := context.Background()
+	key := r.TenantKey("t1", "dashboard", "job-1")
+	payload := `{"rows":"` + strings.Repeat("GetEntry HPD:Help Desk ", 500) + `"}`
+
+	require.NoError(t, r.Set(ctx, key, payload, time.Hour))
+
+	stored, err := mr.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, cacheFormatZstd, stored[0])
+	assert.Less(t, len(stored), len(payload))
+
+	got, err := r.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+}
+
+func TestRedisCache_SmallAndLegacyValuesReadAsIs(t *testing.T) {
+	r, mr := newCacheTestRedis(t, CachePolicy{CompressMinBytes: 1024})
+	ctx := context.Background()
+
+	key := r.TenantKey("t1", "dashboard", "small")
+	require.NoError(t, r.Set(ctx, key, map[string]int{"a": 1}, time.Hour))
+	stored, _ := mr.Get(key)
+	assert.Equal(t, `{"a":1}`, stored)
+
+	// Written before values were compressed.
+	legacy := r.TenantKey("t1", "dashboard", "legacy")
+	require.NoError(t, mr.Set(legacy, `{"total":3}`))
+	got, err := r.Get(ctx, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, `{"total":3}`, got)
+}
+
+func TestRedisCache_TieredTTL(t *testing.T) {
+	r, mr := newCacheTestRedis(t, CachePolicy{TTLTiers: []CacheTTLTier{{MinBytes: 100, MaxTTL: time.Hour}}})
+	ctx := context.Background()
+
+	large := r.TenantKey("t1", "dashboard", "large")
+	require.NoError(t, r.Set(ctx, large, strings.Repeat("x", 200), 24*time.Hour))
+	assert.Equal(t, time.Hour, mr.TTL(large))
+
+	small := r.TenantKey("t1", "dashboard", "small")
+	require.NoError(t, r.Set(ctx, small, "x", 24*time.Hour))
+	assert.Equal(t, 24*time.Hour, mr.TTL(small))
+}
+
+func TestRedisCache_BudgetSkipsWrites(t *testing.T) {
+	r, mr := newCacheTestRedis(t, CachePolicy{TenantBudgetBytes: 250})
+	ctx := context.Background()
+	value := strings.Repeat("x", 100)
+
+	require.NoError(t, r.Set(ctx, r.TenantKey("t1", "dashboard", "a"), value, time.Hour))
+	require.NoError(t, r.Set(ctx, r.TenantKey("t1", "search", "b"), value, time.Hour))
+	require.NoError(t, r.Set(ctx, r.TenantKey("t1", "dashboard", "c"), value, time.Hour), "a skipped write is not an error")
+	assert.False(t, mr.Exists(r.TenantKey("t1", "dashboard", "c")))
+
+	// Other tenants have budgets of their own.
+	require.NoError(t, r.Set(ctx, r.TenantKey("t2", "dashboard", "a"), value, time.Hour))
+	assert.True(t, mr.Exists(r.TenantKey("t2", "dashboard", "a")))
+
+	usage, err := r.TenantCacheUsage(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), usage.Bytes)
+	assert.Equal(t, int64(250), usage.BudgetBytes)
+	assert.Equal(t, map[string]int64{"dashboard": 100, "search": 100}, usage.Namespaces)
+	assert.Equal(t, int64(1), usage.SkippedWrites)
+}
+
+func TestRedisCache_UsageWindow(t *testing.T) {
+	r, _ := newCacheTestRedis(t, CachePolicy{})
+	ctx := context.Background()
+	start := r.now()
+
+	require.NoError(t, r.Set(ctx, r.TenantKey("t1", "dashboard", "a"), "12345", time.Hour))
+	r.now = func() time.Time { return start.Add(5 * time.Hour) }
+	require.NoError(t, r.Set(ctx, r.TenantKey("t1", "dashboard", "b"), "123", time.Hour))
+
+	usage, err := r.TenantCacheUsage(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), usage.Bytes)
+
+	r.now = func() time.Time { return start.Add(CacheUsageWindow + time.Hour) }
+	usage, err = r.TenantCacheUsage(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), usage.Bytes, "writes older than the window no longer count")
+}
+
+func TestRedisCache_AllTenantsCacheUsage(t *testing.T) {
+	r, mr := newCacheTestRedis(t, CachePolicy{})
+	ctx := context.Background()
+
+	require.NoError(t, r.Set(ctx, r.TenantKey("small", "dashboard", "a"), "x", time.Hour))
+	require.NoError(t, r.Set(ctx, r.TenantKey("large", "dashboard", "a"), "xxxx", time.Hour))
+	require.NoError(t, r.Set(ctx, "unrelated", "xxxxxxxx", time.Hour))
+	_, err := mr.SetAdd(cacheUsageTenantsKey, "gone")
+	require.NoError(t, err)
+
+	all, err := r.AllTenantsCacheUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "large", all[0].TenantID)
+	assert.Equal(t, int64(4), all[0].Bytes)
+	assert.Equal(t, "small", all[1].TenantID)
+
+	members, err := mr.Members(cacheUsageTenantsKey)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"large", "small"}, members, "tenants without usage are forgotten")
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// maxJobCacheKeys caps the keys JobCacheKeys returns.
+const maxJobCacheKeys = 500
+
+// JobCacheKeys returns the keys caching data of a job, its dashboard
+// sections and trace waterfalls, with the size of their values, sorted by
+// key. It scans the keyspace, so it is meant for diagnostics rather than
+// request paths.
+func (r *RedisClient) JobCacheKeys(ctx context.Context, tenantID, jobID string) ([]domain.CachedKey, error) {
+	patterns := []string{
+		r.TenantKey(tenantID, "dashboard", jobID) + "*",
+		r.TenantKey(tenantID, "trace:waterfall", jobID) + ":*",
+	}
+	var keys []string
+	for _, pattern := range patterns {
+		iter := r.client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) && len(keys) < maxJobCacheKeys {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("redis: scan job cache keys: %w", err)
+		}
+	}
+	sort.Strings(keys)
+
+	pipe := r.client.Pipeline()
+	sizes := make([]*redis.IntCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, k := range keys {
+		sizes[i] = pipe.StrLen(ctx, k)
+		ttls[i] = pipe.TTL(ctx, k)
+	}
+	if len(keys) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("redis: read job cache keys: %w", err)
+		}
+	}
+
+	out := make([]domain.CachedKey, 0, len(keys))
+	for i, k := range keys {
+		ttl := int64(-1)
+		if d := ttls[i].Val(); d > 0 {
+			ttl = int64(d.Seconds())
+		}
+		out = append(out, domain.CachedKey{Key: k, Bytes: sizes[i].Val(), TTLSeconds: ttl})
+	}
+	return out, nil
+}
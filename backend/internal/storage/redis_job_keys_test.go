@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestJobCacheKeys(t *testing.T) {
+	r, mr := newCacheTestRedis(t, CachePolicy{})
+	ctx := context.Background()
+	const job = "6f1c2d3e-0000-4000-8000-000000000001"
+
+	dashboard := r.TenantKey("t1", "dashboard", job)
+	waterfall := r.TenantKey("t1", "trace:waterfall", job+":trace-1")
+	require.NoError(t, mr.Set(dashboard, strings.Repeat("x", 120)))
+	mr.SetTTL(dashboard, time.Hour)
+	require.NoError(t, mr.Set(dashboard+":queued", "[]"))
+	require.NoError(t, mr.Set(waterfall, "{}"))
+	mr.SetTTL(waterfall, 10*time.Minute)
+	// Keys of another job and another tenant are left out.
+	require.NoError(t, mr.Set(r.TenantKey("t1", "dashboard", "6f1c2d3e-0000-4000-8000-000000000002"), "{}"))
+	require.NoError(t, mr.Set(r.TenantKey("t2", "dashboard", job), "{}"))
+
+	keys, err := r.JobCacheKeys(ctx, "t1", job)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.CachedKey{
+		{Key: dashboard, Bytes: 120, TTLSeconds: 3600},
+		{Key: dashboard + ":queued", Bytes: 2, TTLSeconds: -1},
+		{Key: waterfall, Bytes: 2, TTLSeconds: 600},
+	}, keys)
+
+	keys, err = r.JobCacheKeys(ctx, "t1", "6f1c2d3e-0000-4000-8000-000000000009")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
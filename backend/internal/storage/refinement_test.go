@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowSeqConn answers each QueryRow with the next of its rows.
+type rowSeqConn struct {
+	driver.Conn
+	rows    [][]any
+	queries []string
+}
+
+func (c *rowSeqConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	c.queries = append(c.queries, query)
+	row := c.rows[0]
+	c.rows = c.rows[1:]
+	return &fakeRow{values: row}
+}
+
+func TestGetRefinementStats(t *testing.T) {
+	conn := &rowSeqConn{rows: [][]any{
+		{[]float64{812.4, 5437}, uint64(40)},
+		{uint64(1900), uint64(312)},
+	}}
+	c := &ClickHouseClient{conn: conn}
+
+	stats, err := c.GetRefinementStats(context.Background(), testTenantID, testJobID, SearchQuery{Query: "type:API", Users: []string{"jsmith"}})
+	require.NoError(t, err)
+	assert.Equal(t, &RefinementStats{
+		Failed:     40,
+		SlowerThan: []DurationCount{{ThresholdMS: 800, Count: 1900}, {ThresholdMS: 5000, Count: 312}},
+	}, stats)
+
+	require.Len(t, conn.queries, 2)
+	assert.Contains(t, conn.queries[0], "quantiles(0.9, 0.99)(duration_ms)")
+	assert.Contains(t, conn.queries[1], "countIf(duration_ms > 800), countIf(duration_ms > 5000)")
+	for _, q := range conn.queries {
+		assert.Contains(t, q, "user IN (@users)", "the search's filters apply")
+	}
+}
+
+func TestGetRefinementStats_NoDurations(t *testing.T) {
+	conn := &rowSeqConn{rows: [][]any{{[]float64{0, 0}, uint64(3)}}}
+	c := &ClickHouseClient{conn: conn}
+
+	stats, err := c.GetRefinementStats(context.Background(), testTenantID, testJobID, SearchQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, &RefinementStats{Failed: 3}, stats)
+	assert.Len(t, conn.queries, 1, "no duration query without thresholds")
+}
+
+func TestRoundDownSignificant(t *testing.T) {
+	tests := map[float64]int64{
+		5437:       5000,
+		87:         80,
+		1:          1,
+		9.99:       9,
+		0.5:        0,
+		120000:     100000,
+		math.NaN(): 0,
+	}
+	for v, want := range tests {
+		assert.Equal(t, want, roundDownSignificant(v), "%v", v)
+	}
+}
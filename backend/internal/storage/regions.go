@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrCrossRegion is returned when the data of one region is asked for in
+// the name of a tenant pinned to another.
+var ErrCrossRegion = errors.New("storage: cross-region access refused")
+
+// regionHealthKey is the object probed by the health checks of a region's
+// object storage. It need not exist.
+const regionHealthKey = "health/probe"
+
+// RegionConfig is where the data of the tenants pinned to a region is kept.
+type RegionConfig struct {
+	Objects       ObjectStorageConfig
+	ClickHouseURL string
+}
+
+// ServiceHealth is the outcome of the last health check of a service.
+type ServiceHealth struct {
+	Status    string     `json:"status"` // "ok", "down" or "not_connected"
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// RegionHealth is the health of the storage of one region.
+type RegionHealth struct {
+	Region        string        `json:"region"`
+	ObjectStorage ServiceHealth `json:"object_storage"`
+	ClickHouse    ServiceHealth `json:"clickhouse"`
+}
+
+// regionPool holds the clients of one region. The object storage client is
+// opened by the first use.
+type regionPool struct {
+	cfg RegionConfig
+
+	mu      sync.Mutex
+	objects ObjectStorage
+	health  RegionHealth
+}
+
+// tenantRegion is a cached tenant region.
+type tenantRegion struct {
+	region   string
+	loadedAt time.Time
+}
+
+// Regions resolves the storage clients of the data residency region of
+// each tenant. Tenants without a region use the default object storage and
+// the ClickHouse cluster they are routed to; the statements of a tenant
+// pinned to a region go to the region's cluster, registered by name with
+// the ClickHouse client. Tenant regions are read from Postgres and cached
+// for the TTL.
+type Regions struct {
+	pg      PostgresStore
+	ch      *ClickHouseClient
+	objects ObjectStorage // the default storage
+	pools   map[string]*regionPool
+	ttl     time.Duration
+
+	// openObjects opens the object storage of a region; tests replace it.
+	openObjects func(ctx context.Context, cfg ObjectStorageConfig) (ObjectStorage, error)
+
+	mu      sync.RWMutex
+	tenants map[string]tenantRegion
+}
+
+// NewRegions checks the configuration of every region and registers its
+// ClickHouse as a cluster named after it. No connection is opened.
+func NewRegions(pg PostgresStore, ch *ClickHouseClient, objects ObjectStorage, regions map[string]RegionConfig, ttl time.Duration) (*Regions, error) {
+	r := &Regions{
+		pg:          pg,
+		ch:          ch,
+		objects:     objects,
+		pools:       make(map[string]*regionPool, len(regions)),
+		ttl:         ttl,
+		openObjects: NewObjectStorage,
+		tenants:     make(map[string]tenantRegion),
+	}
+	for name, cfg := range regions {
+		if name == "" || name == DefaultClickHouseCluster {
+			return nil, fmt.Errorf("storage: region name %q is reserved", name)
+		}
+		if err := ch.AddLazyCluster(name, cfg.ClickHouseURL); err != nil {
+			return nil, fmt.Errorf("storage: region %s: %w", name, err)
+		}
+		notConnected := ServiceHealth{Status: "not_connected"}
+		r.pools[name] = &regionPool{cfg: cfg, health: RegionHealth{Region: name, ObjectStorage: notConnected, ClickHouse: notConnected}}
+	}
+
+	ch.router.mu.Lock()
+	ch.router.region = r.cachedRegion
+	ch.router.mu.Unlock()
+	return r, nil
+}
+
+// Names returns the names of the regions, sorted.
+func (r *Regions) Names() []string {
+	names := make([]string, 0, len(r.pools))
+	for name := range r.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Known reports whether region is configured. The empty region, the
+// default storage, always is.
+func (r *Regions) Known(region string) bool {
+	if region == "" {
+		return true
+	}
+	_, ok := r.pools[region]
+	return ok
+}
+
+// TenantRegion returns the region of a tenant, empty for the default
+// storage, from the cache while it is fresh.
+func (r *Regions) TenantRegion(ctx context.Context, tenantID string) (string, error) {
+	r.mu.RLock()
+	cached, ok := r.tenants[tenantID]
+	r.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < r.ttl {
+		return cached.region, nil
+	}
+	return r.RefreshTenant(ctx, tenantID)
+}
+
+// RefreshTenant reloads the region of a tenant from Postgres. A region
+// that is not configured is an error, so that the data of the tenant is
+// never written to another region's storage.
+func (r *Regions) RefreshTenant(ctx context.Context, tenantID string) (string, error) {
+	id, err := uuid.Parse(tenantID)
+	if err != nil {
+		return "", fmt.Errorf("storage: region of tenant %q: invalid tenant ID", tenantID)
+	}
+	tenant, err := r.pg.GetTenant(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("storage: region of tenant %s: %w", tenantID, err)
+	}
+	if !r.Known(tenant.Region) {
+		return "", fmt.Errorf("storage: tenant %s is pinned to region %q, which is not configured", tenantID, tenant.Region)
+	}
+	r.SetTenantRegion(tenantID, tenant.Region)
+	return tenant.Region, nil
+}
+
+// SetTenantRegion caches the region of a tenant that was just changed.
+func (r *Regions) SetTenantRegion(tenantID, region string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants[tenantID] = tenantRegion{region: region, loadedAt: time.Now()}
+}
+
+// cachedRegion returns the cached region of a tenant pinned to one, for the
+// ClickHouse router. It never blocks on Postgres: tenants not in the cache
+// follow their ClickHouse route, which a region change sets too.
+func (r *Regions) cachedRegion(tenantID string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cached, ok := r.tenants[tenantID]
+	return cached.region, ok && cached.region != ""
+}
+
+// ObjectStorage returns the object storage of the region of a tenant. The
+// empty tenant ID stands for data of no tenant, kept in the default storage.
+func (r *Regions) ObjectStorage(ctx context.Context, tenantID string) (ObjectStorage, error) {
+	region := ""
+	if tenantID != "" {
+		var err error
+		if region, err = r.TenantRegion(ctx, tenantID); err != nil {
+			return nil, err
+		}
+	}
+	return r.regionObjects(ctx, region)
+}
+
+// regionObjects returns the object storage of a region, opening it on first
+// use.
+func (r *Regions) regionObjects(ctx context.Context, region string) (ObjectStorage, error) {
+	if region == "" {
+		if r.objects == nil {
+			return nil, fmt.Errorf("storage: object storage not configured")
+		}
+		return r.objects, nil
+	}
+	pool := r.pools[region]
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.objects != nil {
+		return pool.objects, nil
+	}
+	objects, err := r.openObjects(ctx, pool.cfg.Objects)
+	pool.health.ObjectStorage = serviceHealth(err)
+	if err != nil {
+		return nil, fmt.Errorf("storage: region %s: open object storage: %w", region, err)
+	}
+	slog.Info("opened object storage of region", "region", region)
+	pool.objects = objects
+	return objects, nil
+}
+
+// CheckHealth pings the ClickHouse of every region and probes the object
+// storage of the regions whose storage was opened.
+func (r *Regions) CheckHealth(ctx context.Context) {
+	for name, pool := range r.pools {
+		var chErr error
+		if conn, ok := r.ch.router.cluster(name); ok {
+			chErr = conn.Ping(ctx)
+		} else {
+			chErr = fmt.Errorf("cluster not registered")
+		}
+
+		pool.mu.Lock()
+		pool.health.ClickHouse = serviceHealth(chErr)
+		objects := pool.objects
+		pool.mu.Unlock()
+
+		if objects == nil {
+			continue
+		}
+		_, objErr := objects.Exists(ctx, regionHealthKey)
+		pool.mu.Lock()
+		pool.health.ObjectStorage = serviceHealth(objErr)
+		pool.mu.Unlock()
+	}
+}
+
+// WatchHealth checks the health of the regions every interval until ctx
+// is done.
+func (r *Regions) WatchHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			r.CheckHealth(checkCtx)
+			cancel()
+		}
+	}
+}
+
+// Health returns the health of every region, by name.
+func (r *Regions) Health() []RegionHealth {
+	out := make([]RegionHealth, 0, len(r.pools))
+	for _, name := range r.Names() {
+		pool := r.pools[name]
+		pool.mu.Lock()
+		out = append(out, pool.health)
+		pool.mu.Unlock()
+	}
+	return out
+}
+
+func serviceHealth(err error) ServiceHealth {
+	now := time.Now().UTC()
+	if err != nil {
+		return ServiceHealth{Status: "down", Error: err.Error(), CheckedAt: &now}
+	}
+	return ServiceHealth{Status: "ok", CheckedAt: &now}
+}
+
+// regionName names a region in messages.
+func regionName(region string) string {
+	if region == "" {
+		return "default"
+	}
+	return region
+}
+
+// RegionalObjectStorage is an ObjectStorage keeping every object in the
+// region of the tenant it belongs to: the tenant of its key, as keys start
+// with tenants/<tenant ID>/, or else the tenant of the context. A key of a
+// tenant of another region than that of the context is refused with
+// ErrCrossRegion.
+type RegionalObjectStorage struct {
+	regions RegionResolver
+}
+
+// NewRegionalObjectStorage returns an ObjectStorage resolving the storage
+// of every object with regions.
+func NewRegionalObjectStorage(regions RegionResolver) *RegionalObjectStorage {
+	return &RegionalObjectStorage{regions: regions}
+}
+
+// store returns the object storage key belongs in. Writes reload the
+// tenant's region, so that no object lands in the storage of the region the
+// tenant just left.
+func (s *RegionalObjectStorage) store(ctx context.Context, key string, write bool) (ObjectStorage, error) {
+	keyTenant := objectKeyTenant(key)
+	ctxTenant, _ := ctx.Value(routeTenantKey{}).(string)
+	tenantID := keyTenant
+	if tenantID == "" {
+		tenantID = ctxTenant
+	}
+	if tenantID == "" {
+		return s.regions.ObjectStorage(ctx, "")
+	}
+
+	lookup := s.regions.TenantRegion
+	if write {
+		lookup = s.regions.RefreshTenant
+	}
+	region, err := lookup(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if ctxTenant != "" && ctxTenant != tenantID {
+		ctxRegion, err := s.regions.TenantRegion(ctx, ctxTenant)
+		if err != nil {
+			return nil, err
+		}
+		if ctxRegion != region {
+			return nil, fmt.Errorf("%w: object %s of region %s asked for by tenant %s of region %s",
+				ErrCrossRegion, key, regionName(region), ctxTenant, regionName(ctxRegion))
+		}
+	}
+	return s.regions.ObjectStorage(ctx, tenantID)
+}
+
+// objectKeyTenant returns the tenant of an object key of the form
+// tenants/<tenant ID>/..., or empty.
+func objectKeyTenant(key string) string {
+	rest, ok := strings.CutPrefix(key, "tenants/")
+	if !ok {
+		return ""
+	}
+	tenantID, _, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ""
+	}
+	return tenantID
+}
+
+func (s *RegionalObjectStorage) Upload(ctx context.Context, key string, reader io.Reader, size int64) error {
+	store, err := s.store(ctx, key, true)
+	if err != nil {
+		return err
+	}
+	return store.Upload(ctx, key, reader, size)
+}
+
+func (s *RegionalObjectStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	store, err := s.store(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	return store.Download(ctx, key)
+}
+
+func (s *RegionalObjectStorage) Delete(ctx context.Context, key string) error {
+	store, err := s.store(ctx, key, false)
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, key)
+}
+
+func (s *RegionalObjectStorage) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	store, err := s.store(ctx, key, false)
+	if err != nil {
+		return "", err
+	}
+	return store.PresignGetURL(ctx, key, expiry)
+}
+
+func (s *RegionalObjectStorage) Exists(ctx context.Context, key string) (bool, error) {
+	store, err := s.store(ctx, key, false)
+	if err != nil {
+		return false, err
+	}
+	return store.Exists(ctx, key)
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// regionTenants is a PostgresStore holding only the regions of tenants.
+type regionTenants struct {
+	PostgresStore
+	regions map[uuid.UUID]string
+	loads   int
+}
+
+func (p *regionTenants) GetTenant(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
+	p.loads++
+	region, ok := p.regions[id]
+	if !ok {
+		return nil, fmt.Errorf("postgres: tenant not found: %s", id)
+	}
+	return &domain.Tenant{ID: id, Region: region}, nil
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error { return nil }
+
+var (
+	euTenant  = uuid.MustParse("00000000-0000-0000-0000-0000000000e1")
+	usTenant  = uuid.MustParse("00000000-0000-0000-0000-0000000000a1")
+	usTenant2 = uuid.MustParse("00000000-0000-0000-0000-0000000000a2")
+)
+
+// newTestRegions returns regions with an "eu" region whose objects are kept
+// on the filesystem and whose ClickHouse is eu, next to a default storage
+// and cluster.
+func newTestRegions(t *testing.T, pg PostgresStore) (r *Regions, def ObjectStorage, eu *fakeConn, other *fakeConn) {
+	t.Helper()
+	def, err := NewFilesystemStorage(t.TempDir())
+	require.NoError(t, err)
+	other, eu = &fakeConn{}, &fakeConn{}
+	router := newClusterRouter(other)
+	ch := &ClickHouseClient{conn: taggedConn{router}, router: router}
+
+	r, err = NewRegions(pg, ch, def, map[string]RegionConfig{
+		"eu": {
+			Objects:       ObjectStorageConfig{Backend: BackendFilesystem, FilesystemRoot: t.TempDir()},
+			ClickHouseURL: "clickhouse://localhost:19000/remedyiq",
+		},
+	}, time.Minute)
+	require.NoError(t, err)
+	ch.router.clusters["eu"] = eu
+	return r, def, eu, other
+}
+
+func TestRegions_RoutesTenantData(t *testing.T) {
+	pg := &regionTenants{regions: map[uuid.UUID]string{euTenant: "eu", usTenant: ""}}
+	r, def, eu, other := newTestRegions(t, pg)
+	objects := NewRegionalObjectStorage(r)
+	ctx := context.Background()
+
+	euKey := "tenants/" + euTenant.String() + "/jobs/f/a.log"
+	usKey := "tenants/" + usTenant.String() + "/jobs/f/a.log"
+	require.NoError(t, objects.Upload(WithTenant(ctx, euTenant.String()), euKey, strings.NewReader("eu"), 2))
+	require.NoError(t, objects.Upload(ctx, usKey, strings.NewReader("us"), 2))
+
+	ok, err := def.Exists(ctx, euKey)
+	require.NoError(t, err)
+	assert.False(t, ok, "the EU tenant's object stays out of the default storage")
+	ok, err = def.Exists(ctx, usKey)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	euObjects, err := r.ObjectStorage(ctx, euTenant.String())
+	require.NoError(t, err)
+	ok, err = euObjects.Exists(ctx, euKey)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Entries of the EU tenant go to the region's ClickHouse, the others
+	// follow their routes.
+	ch := r.ch
+	require.NoError(t, ch.DeleteChunk(WithTenant(ctx, euTenant.String()), "log_entries", euTenant.String(), "j", 0, 10))
+	require.NoError(t, ch.DeleteChunk(WithTenant(ctx, usTenant.String()), "log_entries", usTenant.String(), "j", 0, 10))
+	assert.Len(t, eu.execs, 1)
+	assert.Len(t, other.execs, 1)
+}
+
+func TestRegions_TenantRegionCached(t *testing.T) {
+	pg := &regionTenants{regions: map[uuid.UUID]string{euTenant: "eu"}}
+	r, _, _, _ := newTestRegions(t, pg)
+	ctx := context.Background()
+
+	for range 3 {
+		region, err := r.TenantRegion(ctx, euTenant.String())
+		require.NoError(t, err)
+		assert.Equal(t, "eu", region)
+	}
+	assert.Equal(t, 1, pg.loads)
+
+	pg.regions[euTenant] = ""
+	region, err := r.RefreshTenant(ctx, euTenant.String())
+	require.NoError(t, err)
+	assert.Empty(t, region)
+	assert.Equal(t, 2, pg.loads)
+}
+
+func TestRegions_UnknownRegionRefused(t *testing.T) {
+	pg := &regionTenants{regions: map[uuid.UUID]string{euTenant: "apac"}}
+	r, _, _, _ := newTestRegions(t, pg)
+	objects := NewRegionalObjectStorage(r)
+
+	err := objects.Upload(context.Background(), "tenants/"+euTenant.String()+"/jobs/f/a.log", strings.NewReader("x"), 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `region "apac", which is not configured`)
+
+	_, err = r.TenantRegion(context.Background(), "not-a-uuid")
+	assert.Error(t, err)
+}
+
+func TestRegionalObjectStorage_RefusesCrossRegionAccess(t *testing.T) {
+	pg := &regionTenants{regions: map[uuid.UUID]string{euTenant: "eu", usTenant: "", usTenant2: ""}}
+	r, _, _, _ := newTestRegions(t, pg)
+	objects := NewRegionalObjectStorage(r)
+	ctx := context.Background()
+
+	euKey := "tenants/" + euTenant.String() + "/exports/x.tar.gz"
+	usKey := "tenants/" + usTenant.String() + "/exports/x.tar.gz"
+	require.NoError(t, objects.Upload(ctx, euKey, strings.NewReader("eu"), 2))
+	require.NoError(t, objects.Upload(ctx, usKey, strings.NewReader("us"), 2))
+
+	usCtx := WithTenant(ctx, usTenant.String())
+	_, err := objects.Download(usCtx, euKey)
+	assert.True(t, errors.Is(err, ErrCrossRegion), "got %v", err)
+	_, err = objects.Exists(usCtx, euKey)
+	assert.True(t, errors.Is(err, ErrCrossRegion))
+	assert.True(t, errors.Is(objects.Delete(usCtx, euKey), ErrCrossRegion))
+	_, err = objects.PresignGetURL(usCtx, euKey, time.Minute)
+	assert.True(t, errors.Is(err, ErrCrossRegion))
+
+	_, err = objects.Download(WithTenant(ctx, euTenant.String()), usKey)
+	assert.True(t, errors.Is(err, ErrCrossRegion))
+
+	// Tenants of the same region are not told apart here.
+	rc, err := objects.Download(WithTenant(ctx, usTenant2.String()), usKey)
+	require.NoError(t, err)
+	rc.Close()
+}
+
+func TestRegions_LazyOpenAndHealth(t *testing.T) {
+	pg := &regionTenants{regions: map[uuid.UUID]string{euTenant: "eu"}}
+	r, _, _, _ := newTestRegions(t, pg)
+	opened := 0
+	r.openObjects = func(ctx context.Context, cfg ObjectStorageConfig) (ObjectStorage, error) {
+		opened++
+		if opened == 1 {
+			return nil, errors.New("bucket unreachable")
+		}
+		return NewObjectStorage(ctx, cfg)
+	}
+
+	health := r.Health()
+	require.Len(t, health, 1)
+	assert.Equal(t, "eu", health[0].Region)
+	assert.Equal(t, "not_connected", health[0].ObjectStorage.Status)
+	assert.Equal(t, "not_connected", health[0].ClickHouse.Status)
+	assert.Equal(t, 0, opened, "nothing is opened before use")
+
+	ctx := context.Background()
+	_, err := r.ObjectStorage(ctx, euTenant.String())
+	require.ErrorContains(t, err, "bucket unreachable")
+	assert.Equal(t, "down", r.Health()[0].ObjectStorage.Status)
+
+	_, err = r.ObjectStorage(ctx, euTenant.String())
+	require.NoError(t, err)
+	_, err = r.ObjectStorage(ctx, euTenant.String())
+	require.NoError(t, err)
+	assert.Equal(t, 2, opened, "the storage is opened once")
+
+	r.CheckHealth(ctx)
+	health = r.Health()
+	assert.Equal(t, "ok", health[0].ObjectStorage.Status)
+	assert.Equal(t, "ok", health[0].ClickHouse.Status)
+	assert.NotNil(t, health[0].ClickHouse.CheckedAt)
+}
+
+func TestNewRegions_ReservedName(t *testing.T) {
+	ch := routedClient(&fakeConn{}, &fakeConn{})
+	_, err := NewRegions(&regionTenants{}, ch, nil, map[string]RegionConfig{
+		DefaultClickHouseCluster: {ClickHouseURL: "clickhouse://localhost:19000"},
+	}, time.Minute)
+	assert.Error(t, err)
+
+	_, err = NewRegions(&regionTenants{}, ch, nil, map[string]RegionConfig{
+		"eu": {ClickHouseURL: "http://%zz"},
+	}, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestObjectKeyTenant(t *testing.T) {
+	tests := map[string]string{
+		"tenants/t1/jobs/f/a.log":       "t1",
+		"tenants/t1/exports/x.tar.gz":   "t1",
+		"tenants/t1":                    "",
+		"health/probe":                  "",
+		"uploads/tenants/t1/jobs/a.log": "",
+	}
+	for key, want := range tests {
+		assert.Equal(t, want, objectKeyTenant(key), key)
+	}
+}
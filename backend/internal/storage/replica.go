@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Defaults of ReplicaConfig.
+const (
+	DefaultReplicaFailureThreshold = 3
+	DefaultReplicaCooldown         = 30 * time.Second
+	DefaultReplicaMaxStaleness     = 10 * time.Second
+	DefaultReplicaCheckInterval    = 2 * time.Second
+)
+
+// ReplicaConfig tunes the use of a read replica.
+type ReplicaConfig struct {
+	// FailureThreshold consecutive failures of the replica stop reads from
+	// going to it for Cooldown, after which they are tried again.
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	// MaxStaleness bounds the replication lag of a Postgres replica: reads
+	// go to the primary while the replica may be further behind. Unused by
+	// ClickHouse.
+	MaxStaleness time.Duration
+
+	// CheckInterval is the interval of the replica's health checks.
+	CheckInterval time.Duration
+}
+
+// DefaultReplicaConfig returns the default replica configuration.
+func DefaultReplicaConfig() ReplicaConfig {
+	return ReplicaConfig{
+		FailureThreshold: DefaultReplicaFailureThreshold,
+		Cooldown:         DefaultReplicaCooldown,
+		MaxStaleness:     DefaultReplicaMaxStaleness,
+		CheckInterval:    DefaultReplicaCheckInterval,
+	}
+}
+
+func (c ReplicaConfig) withDefaults() ReplicaConfig {
+	def := DefaultReplicaConfig()
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = def.FailureThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = def.Cooldown
+	}
+	if c.MaxStaleness <= 0 {
+		c.MaxStaleness = def.MaxStaleness
+	}
+	if c.CheckInterval <= 0 {
+		c.CheckInterval = def.CheckInterval
+	}
+	return c
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose statements all run on the primary,
+// even those of read-only methods, for reads that must see the writes the
+// caller just made.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether the statements of ctx are pinned to the
+// primary by WithPrimary.
+func UsesPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// replicaHealth decides whether reads may go to a replica. It opens like a
+// circuit breaker after consecutive failures of the replica's statements or
+// health checks, and, when it guards staleness, only lets reads through
+// while the lag measured by the last check, plus the time since, is within
+// the bound: the replica cannot have fallen further behind than that.
+type replicaHealth struct {
+	cfg        ReplicaConfig
+	guardStale bool
+	now        func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	lag       time.Duration
+	checkedAt time.Time // of the last successful check; zero before
+}
+
+func newReplicaHealth(cfg ReplicaConfig, guardStale bool) *replicaHealth {
+	return &replicaHealth{cfg: cfg.withDefaults(), guardStale: guardStale, now: time.Now}
+}
+
+// usable reports whether reads may go to the replica.
+func (h *replicaHealth) usable() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	if now.Before(h.openUntil) {
+		return false
+	}
+	if !h.guardStale {
+		return true
+	}
+	if h.checkedAt.IsZero() {
+		return false
+	}
+	return h.lag+now.Sub(h.checkedAt) <= h.cfg.MaxStaleness
+}
+
+// failure records a failure of the replica, opening the breaker once they
+// reach the threshold.
+func (h *replicaHealth) failure(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures++
+	if h.failures >= h.cfg.FailureThreshold {
+		if !h.now().Before(h.openUntil) {
+			slog.Warn("read replica unavailable, reading from the primary", "cooldown", h.cfg.Cooldown, "error", err)
+		}
+		h.openUntil = h.now().Add(h.cfg.Cooldown)
+	}
+}
+
+// success records a statement the replica ran.
+func (h *replicaHealth) success() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures = 0
+}
+
+// checked records the outcome of a health check and the lag it measured.
+// A successful check closes the breaker.
+func (h *replicaHealth) checked(lag time.Duration, err error) {
+	if err != nil {
+		h.failure(err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures = 0
+	h.openUntil = time.Time{}
+	h.lag = lag
+	h.checkedAt = h.now()
+}
+
+// watchReplica runs check every interval of the configuration until ctx is
+// done.
+func watchReplica(ctx context.Context, h *replicaHealth, check func(ctx context.Context) (time.Duration, error)) {
+	ticker := time.NewTicker(h.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, h.cfg.CheckInterval)
+			lag, err := check(checkCtx)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			h.checked(lag, err)
+		}
+	}
+}
+
+// replicaFailed reports whether err, returned by a replica statement of
+// ctx, is worth retrying on the primary: the caller's cancellation is not,
+// nor are the errors the primary would return as well, which isQueryError
+// recognizes.
+func replicaFailed(ctx context.Context, err error, isQueryError func(error) bool) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return !isQueryError(err)
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReplicaHealth is a replicaHealth on a clock the test moves.
+func testReplicaHealth(guardStale bool) (*replicaHealth, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := newReplicaHealth(ReplicaConfig{FailureThreshold: 2, Cooldown: time.Minute, MaxStaleness: 10 * time.Second}, guardStale)
+	h.now = func() time.Time { return now }
+	return h, &now
+}
+
+func TestReplicaHealth_BreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	h, now := testReplicaHealth(false)
+	assert.True(t, h.usable())
+
+	h.failure(errors.New("connection refused"))
+	h.success()
+	h.failure(errors.New("connection refused"))
+	assert.True(t, h.usable(), "a success resets the count")
+
+	h.failure(errors.New("connection refused"))
+	assert.False(t, h.usable())
+
+	*now = now.Add(time.Minute)
+	assert.True(t, h.usable(), "tried again after the cooldown")
+	h.failure(errors.New("connection refused"))
+	assert.False(t, h.usable(), "a failure after the cooldown opens it again")
+
+	h.checked(0, nil)
+	assert.True(t, h.usable(), "a successful check closes it")
+}
+
+func TestReplicaHealth_StalenessGuard(t *testing.T) {
+	h, now := testReplicaHealth(true)
+	assert.False(t, h.usable(), "not read from before its lag is known")
+
+	h.checked(4*time.Second, nil)
+	assert.True(t, h.usable())
+
+	*now = now.Add(6 * time.Second)
+	assert.True(t, h.usable(), "at most 10s behind")
+	*now = now.Add(time.Second)
+	assert.False(t, h.usable(), "may be 11s behind without a newer check")
+
+	h.checked(0, nil)
+	assert.True(t, h.usable())
+	h.checked(11*time.Second, nil)
+	assert.False(t, h.usable(), "measured too far behind")
+}
+
+// fakePGReader records the statements it runs, failing them with err.
+type fakePGReader struct {
+	queries int
+	rows    int
+	err     error
+	value   int
+}
+
+func (f *fakePGReader) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	f.queries++
+	return nil, f.err
+}
+
+func (f *fakePGReader) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	f.rows++
+	return fakePGRow{f}
+}
+
+func (f *fakePGReader) Close() {}
+
+type fakePGRow struct{ f *fakePGReader }
+
+func (r fakePGRow) Scan(dest ...any) error {
+	if r.f.err != nil {
+		return r.f.err
+	}
+	*dest[0].(*int) = r.f.value
+	return nil
+}
+
+func TestPostgresReader_Routing(t *testing.T) {
+	h, _ := testReplicaHealth(true)
+	h.checked(0, nil)
+	replica := &fakePGReader{}
+	p := &PostgresClient{replica: replica, replicaHealth: h}
+	ctx := context.Background()
+
+	assert.IsType(t, &replicaReader{}, p.reader(ctx))
+	_, pinned := p.reader(WithPrimary(ctx)).(*replicaReader)
+	assert.False(t, pinned, "WithPrimary pins reads to the primary")
+
+	h.checked(time.Minute, nil)
+	_, stale := p.reader(ctx).(*replicaReader)
+	assert.False(t, stale, "a stale replica is not read from")
+
+	_, none := (&PostgresClient{}).reader(ctx).(*replicaReader)
+	assert.False(t, none)
+}
+
+func TestReplicaReader_FallsBackToPrimary(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replica down", func(t *testing.T) {
+		h, _ := testReplicaHealth(false)
+		primary := &fakePGReader{value: 7}
+		replica := &fakePGReader{err: errors.New("dial tcp: connection refused")}
+		r := &replicaReader{primary: primary, replica: replica, health: h}
+
+		var v int
+		require.NoError(t, r.QueryRow(ctx, "SELECT 1").Scan(&v))
+		assert.Equal(t, 7, v)
+		_, err := r.Query(ctx, "SELECT 1")
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, primary.rows)
+		assert.Equal(t, 1, primary.queries)
+		assert.False(t, h.usable(), "two failures open the breaker")
+	})
+
+	t.Run("conflict with recovery", func(t *testing.T) {
+		h, _ := testReplicaHealth(false)
+		primary := &fakePGReader{}
+		replica := &fakePGReader{err: &pgconn.PgError{Code: "40001"}}
+		r := &replicaReader{primary: primary, replica: replica, health: h}
+
+		_, err := r.Query(ctx, "SELECT 1")
+		require.NoError(t, err)
+		assert.Equal(t, 1, primary.queries)
+	})
+
+	t.Run("errors of the statement", func(t *testing.T) {
+		for _, replicaErr := range []error{pgx.ErrNoRows, &pgconn.PgError{Code: "42P01"}, context.Canceled} {
+			h, _ := testReplicaHealth(false)
+			primary := &fakePGReader{}
+			r := &replicaReader{primary: primary, replica: &fakePGReader{err: replicaErr}, health: h}
+
+			var v int
+			assert.ErrorIs(t, r.QueryRow(ctx, "SELECT 1").Scan(&v), replicaErr)
+			assert.Zero(t, primary.rows, "%v is not retried on the primary", replicaErr)
+			h.failure(errors.New("x"))
+			assert.True(t, h.usable(), "%v is not a failure of the replica", replicaErr)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// restartGapSlack is how long after a gap ends a restart may still be dated
+// and explain it: its first entry is the one ending the gap, give or take
+// the entries sampled for detection.
+const restartGapSlack = time.Second
+
+// restartExplainsGap returns the restart the gap in logging from start to
+// end is the downtime of, or nil.
+func restartExplainsGap(restarts []domain.RestartEvent, start, end time.Time) *domain.RestartEvent {
+	for i := range restarts {
+		if restarts[i].Explains(start, end, restartGapSlack) {
+			return &restarts[i]
+		}
+	}
+	return nil
+}
+
+// restartWarmupFilter returns a ClickHouse condition true for entries
+// outside the warm-up windows of restarts, its parameters and the number
+// of windows. Without windows the condition is always true.
+func restartWarmupFilter(restarts []domain.RestartEvent) (string, []any, int) {
+	var conds []string
+	var args []any
+	for i, r := range restarts {
+		if r.WarmupEnd == nil {
+			continue
+		}
+		conds = append(conds, fmt.Sprintf("(timestamp >= @warmupStart%d AND timestamp < @warmupEnd%d)", i, i))
+		args = append(args,
+			clickhouse.Named(fmt.Sprintf("warmupStart%d", i), r.Timestamp.Time),
+			clickhouse.Named(fmt.Sprintf("warmupEnd%d", i), r.WarmupEnd.Time),
+		)
+	}
+	if len(conds) == 0 {
+		return "1", nil, 0
+	}
+	return "NOT (" + strings.Join(conds, " OR ") + ")", args, len(conds)
+}
+
+// LabelRestartGaps marks the gaps of resp that are the downtime of one of
+// restarts and lists the restarts with them. The hint of such a gap is
+// reclassified as a server restart, keeping its evidence.
+func LabelRestartGaps(resp *domain.GapsResponse, restarts []domain.RestartEvent) {
+	if len(restarts) == 0 {
+		return
+	}
+	resp.Restarts = restarts
+	for i := range resp.Gaps {
+		g := &resp.Gaps[i]
+		r := restartExplainsGap(restarts, g.StartTime.Time, g.EndTime.Time)
+		if r == nil {
+			continue
+		}
+		g.Restart = r
+		if g.Hint == nil {
+			g.Hint = &domain.GapHint{
+				ThreadIDs:     []string{},
+				PreGapEntries: []domain.GapThreadEntry{},
+			}
+		}
+		g.Hint.Classification = domain.GapServerRestart
+		g.Hint.Reason = fmt.Sprintf("the server restarted at %s (confidence %.2f)", r.Timestamp, r.Confidence)
+	}
+}
+
+// LabelRestartJARGaps marks the line gaps the JAR reported that are the
+// downtime of one of restarts and lists the restarts with them. The JAR
+// dates a gap by one of the entries around it, so it may have either end.
+func LabelRestartJARGaps(resp *domain.JARGapsResponse, restarts []domain.RestartEvent) {
+	if len(restarts) == 0 {
+		return
+	}
+	resp.Restarts = restarts
+	for i := range resp.LineGaps {
+		g := &resp.LineGaps[i]
+		d := time.Duration(g.GapDuration * float64(time.Second))
+		g.Restart = restartExplainsGap(restarts, g.Timestamp.Add(-d), g.Timestamp.Add(d))
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestLabelRestartGaps(t *testing.T) {
+	base := time.Date(2025, 11, 24, 14, 40, 0, 0, time.UTC)
+	ts := func(d time.Duration) domain.Timestamp { return domain.NewTimestamp(base.Add(d)) }
+	restarts := []domain.RestartEvent{{Timestamp: ts(5 * time.Minute), Confidence: 0.95}}
+
+	resp := &domain.GapsResponse{Gaps: []domain.GapEntry{
+		{StartTime: ts(time.Minute), EndTime: ts(5 * time.Minute), Hint: &domain.GapHint{Classification: domain.GapLogRotation, ThreadIDs: []string{"532"}}},
+		{StartTime: ts(10 * time.Minute), EndTime: ts(12 * time.Minute)},
+	}}
+	LabelRestartGaps(resp, restarts)
+
+	assert.Equal(t, restarts, resp.Restarts)
+	require.NotNil(t, resp.Gaps[0].Restart)
+	assert.Equal(t, domain.GapServerRestart, resp.Gaps[0].Hint.Classification)
+	assert.Equal(t, []string{"532"}, resp.Gaps[0].Hint.ThreadIDs, "the evidence of the hint is kept")
+	assert.Nil(t, resp.Gaps[1].Restart)
+	assert.Nil(t, resp.Gaps[1].Hint)
+
+	jar := &domain.JARGapsResponse{LineGaps: []domain.JARGapEntry{
+		{GapDuration: 240, Timestamp: ts(time.Minute)},
+		{GapDuration: 30, Timestamp: ts(20 * time.Minute)},
+	}}
+	LabelRestartJARGaps(jar, restarts)
+	assert.NotNil(t, jar.LineGaps[0].Restart)
+	assert.Nil(t, jar.LineGaps[1].Restart)
+}
+
+func TestRestartWarmupFilter(t *testing.T) {
+	cond, args, n := restartWarmupFilter(nil)
+	assert.Equal(t, "1", cond)
+	assert.Empty(t, args)
+	assert.Zero(t, n)
+
+	end := domain.NewTimestamp(time.Now().Add(time.Minute))
+	cond, args, n = restartWarmupFilter([]domain.RestartEvent{
+		{Timestamp: domain.NewTimestamp(time.Now())},
+		{Timestamp: domain.NewTimestamp(time.Now()), WarmupEnd: &end},
+	})
+	assert.Equal(t, "NOT ((timestamp >= @warmupStart1 AND timestamp < @warmupEnd1))", cond)
+	assert.Len(t, args, 2)
+	assert.Equal(t, 1, n)
+}
+
+func TestScoreHealth_DescribesRestartExclusions(t *testing.T) {
+	h := scoreHealth(healthMetrics{maxGapSeconds: 2, restartGaps: 1, warmupWindows: 1}, DefaultHealthProfile())
+	assert.Contains(t, h.Factors[1].Description, "excluding 1 restart warm-up window(s)")
+	assert.Contains(t, h.Factors[3].Description, "excluding 1 server restart(s)")
+}
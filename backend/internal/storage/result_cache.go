@@ -0,0 +1,405 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// resultCacheCategory is the TenantKey category of cached results.
+	resultCacheCategory = "chresult"
+
+	// DefaultResultCacheTTL is how long a cached result is served.
+	DefaultResultCacheTTL = 5 * time.Minute
+
+	// DefaultResultCacheMaxBytes caps the JSON size of a cached result;
+	// larger results are read from ClickHouse every time.
+	DefaultResultCacheMaxBytes = 256 << 10
+)
+
+// ResultCacheConfig configures the ClickHouse result cache. Zero values
+// select the defaults.
+type ResultCacheConfig struct {
+	TTL           time.Duration
+	MaxEntryBytes int
+}
+
+// ResultCacheStats counts the statements of the reads opted in to the
+// result cache since the process started: served from Redis, sent to
+// ClickHouse and cached, and sent to ClickHouse uncached because the job
+// was not complete or the result was too large.
+type ResultCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Bypasses  int64 `json:"bypasses"`
+	Oversized int64 `json:"oversized"`
+}
+
+// resultCache keeps the rows of ClickHouse statements in Redis. The entries
+// of a job are keyed by the time it completed, so reprocessing the job
+// leaves them behind to expire.
+type resultCache struct {
+	redis    RedisCache
+	jobs     PostgresStore
+	ttl      time.Duration
+	maxBytes int
+
+	hits, misses, bypasses, oversized atomic.Int64
+}
+
+// SetResultCache caches in redis the results of the read methods that opt
+// in, for the jobs jobs reports complete. It wraps the connection and must
+// be called before the client is used.
+func (c *ClickHouseClient) SetResultCache(cache RedisCache, jobs PostgresStore, cfg ResultCacheConfig) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultResultCacheTTL
+	}
+	if cfg.MaxEntryBytes <= 0 {
+		cfg.MaxEntryBytes = DefaultResultCacheMaxBytes
+	}
+	c.results = &resultCache{redis: cache, jobs: jobs, ttl: cfg.TTL, maxBytes: cfg.MaxEntryBytes}
+	c.conn = resultCachingConn{Conn: c.conn}
+}
+
+// ResultCacheStats returns the counts of the result cache, zero when it is
+// disabled.
+func (c *ClickHouseClient) ResultCacheStats() ResultCacheStats {
+	if c.results == nil {
+		return ResultCacheStats{}
+	}
+	return ResultCacheStats{
+		Hits:      c.results.hits.Load(),
+		Misses:    c.results.misses.Load(),
+		Bypasses:  c.results.bypasses.Load(),
+		Oversized: c.results.oversized.Load(),
+	}
+}
+
+type cachedReadKey struct{}
+
+// cachedRead marks the context of a read whose statements are cached, for
+// a complete job at its completion version.
+type cachedRead struct {
+	cache    *resultCache
+	tenantID string
+	version  string
+}
+
+// cacheReads opts the statements run with the returned context in to the
+// result cache. Only reads of immutable job data may opt in: their results
+// must depend on nothing but the statement, its arguments and the job's
+// entries. Jobs that are not complete are read from ClickHouse.
+func (c *ClickHouseClient) cacheReads(ctx context.Context, tenantID, jobID string) context.Context {
+	if c.results == nil {
+		return ctx
+	}
+	version, ok := c.results.jobVersion(ctx, tenantID, jobID)
+	if !ok {
+		c.results.bypasses.Add(1)
+		return ctx
+	}
+	return context.WithValue(ctx, cachedReadKey{}, &cachedRead{cache: c.results, tenantID: tenantID, version: version})
+}
+
+// jobVersion returns the completion version of a job, or false when the
+// job is not complete or cannot be looked up.
+func (rc *resultCache) jobVersion(ctx context.Context, tenantID, jobID string) (string, bool) {
+	tid, err := uuid.Parse(tenantID)
+	if err != nil {
+		return "", false
+	}
+	jid, err := uuid.Parse(jobID)
+	if err != nil {
+		return "", false
+	}
+	job, err := rc.jobs.GetJob(ctx, tid, jid)
+	if err != nil {
+		slog.Debug("result cache: job lookup failed", "job_id", jobID, "error", err)
+		return "", false
+	}
+	if job.Status != domain.JobStatusComplete || job.CompletedAt == nil {
+		return "", false
+	}
+	return jobID + "@" + strconv.FormatInt(job.CompletedAt.UnixNano(), 10), true
+}
+
+// key returns the cache key of a statement: a fingerprint of its
+// whitespace-normalized SQL, its arguments and the job version.
+func (r *cachedRead) key(kind, query string, args []any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", kind, r.version, strings.Join(strings.Fields(query), " "))
+	parts := make([]string, 0, len(args))
+	for i, arg := range args {
+		if named, ok := arg.(driver.NamedValue); ok {
+			parts = append(parts, fmt.Sprintf("@%s=%T:%v", named.Name, named.Value, named.Value))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("$%d=%T:%v", i, arg, arg))
+	}
+	// Named arguments bind by name, so their order does not matter.
+	sort.Strings(parts)
+	for _, p := range parts {
+		fmt.Fprintf(h, "%s\x00", p)
+	}
+	return r.cache.redis.TenantKey(r.tenantID, resultCacheCategory, hex.EncodeToString(h.Sum(nil)))
+}
+
+// cachedResult is the cached result of a statement: the JSON of the
+// values scanned from each of its rows.
+type cachedResult struct {
+	Rows [][]json.RawMessage `json:"rows"`
+}
+
+func (r *cachedRead) load(ctx context.Context, key string) (*cachedResult, bool) {
+	stored, err := r.cache.redis.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.Debug("result cache get failed", "error", err)
+		}
+		return nil, false
+	}
+	// The value is stored compressed; Redis decompresses it only when its
+	// own policy compressed it too.
+	payload, err := decodeCacheValue(stored)
+	if err != nil {
+		return nil, false
+	}
+	var res cachedResult
+	if err := json.Unmarshal([]byte(payload), &res); err != nil {
+		return nil, false
+	}
+	return &res, true
+}
+
+func (r *cachedRead) store(ctx context.Context, key string, res *cachedResult) {
+	payload, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	if len(payload) > r.cache.maxBytes {
+		r.cache.oversized.Add(1)
+		return
+	}
+	if err := r.cache.redis.Set(ctx, key, encodeCacheValue(payload, 1), r.cache.ttl); err != nil {
+		slog.Warn("result cache set failed", "error", err)
+	}
+}
+
+// resultCachingConn is a driver.Conn serving the queries of cached reads
+// from the result cache, and caching their results on a miss.
+type resultCachingConn struct {
+	driver.Conn
+}
+
+func (c resultCachingConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	read, ok := ctx.Value(cachedReadKey{}).(*cachedRead)
+	if !ok {
+		return c.Conn.Query(ctx, query, args...)
+	}
+	key := read.key("rows", query, args)
+	if res, ok := read.load(ctx, key); ok {
+		read.cache.hits.Add(1)
+		return &replayRows{res: res, i: -1}, nil
+	}
+	read.cache.misses.Add(1)
+	rows, err := c.Conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingRows{
+		Rows: rows, ctx: ctx, read: read, key: key,
+		res:       &cachedResult{Rows: [][]json.RawMessage{}},
+		recording: true,
+	}, nil
+}
+
+func (c resultCachingConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	read, ok := ctx.Value(cachedReadKey{}).(*cachedRead)
+	if !ok {
+		return c.Conn.QueryRow(ctx, query, args...)
+	}
+	key := read.key("row", query, args)
+	if res, ok := read.load(ctx, key); ok {
+		read.cache.hits.Add(1)
+		return &replayRow{res: res}
+	}
+	read.cache.misses.Add(1)
+	return &recordingRow{Row: c.Conn.QueryRow(ctx, query, args...), ctx: ctx, read: read, key: key}
+}
+
+// encodeScanned returns the JSON of the values scanned into dest, and
+// their total size.
+func encodeScanned(dest []any) ([]json.RawMessage, int, bool) {
+	row := make([]json.RawMessage, len(dest))
+	size := 0
+	for i, d := range dest {
+		data, err := json.Marshal(d)
+		if err != nil {
+			return nil, 0, false
+		}
+		row[i] = data
+		size += len(data)
+	}
+	return row, size, true
+}
+
+// decodeScanned scans the cached values of row into dest.
+func decodeScanned(row []json.RawMessage, dest []any) error {
+	if len(row) != len(dest) {
+		return fmt.Errorf("clickhouse: result cache: %d values cached, %d scanned", len(row), len(dest))
+	}
+	for i := range row {
+		if err := json.Unmarshal(row[i], dest[i]); err != nil {
+			return fmt.Errorf("clickhouse: result cache: column %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// recordingRows passes the rows of a statement through, keeping the values
+// scanned from them, and caches them once every row was read. A result
+// growing past the size limit, or read in a way the cache cannot replay,
+// is not cached.
+type recordingRows struct {
+	driver.Rows
+	ctx       context.Context
+	read      *cachedRead
+	key       string
+	res       *cachedResult
+	size      int
+	recording bool
+}
+
+func (r *recordingRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	if r.recording && r.Rows.Err() == nil {
+		r.recording = false
+		r.read.store(r.ctx, r.key, r.res)
+	}
+	return false
+}
+
+func (r *recordingRows) Scan(dest ...any) error {
+	if err := r.Rows.Scan(dest...); err != nil {
+		r.recording = false
+		return err
+	}
+	if !r.recording {
+		return nil
+	}
+	row, size, ok := encodeScanned(dest)
+	if !ok {
+		r.recording = false
+		return nil
+	}
+	r.size += size
+	if r.size > r.read.cache.maxBytes {
+		r.recording = false
+		r.res = nil
+		r.read.cache.oversized.Add(1)
+		return nil
+	}
+	r.res.Rows = append(r.res.Rows, row)
+	return nil
+}
+
+func (r *recordingRows) ScanStruct(dest any) error {
+	r.recording = false
+	return r.Rows.ScanStruct(dest)
+}
+
+func (r *recordingRows) Totals(dest ...any) error {
+	r.recording = false
+	return r.Rows.Totals(dest...)
+}
+
+// recordingRow passes the row of a statement through and caches the values
+// scanned from it, or that it had none.
+type recordingRow struct {
+	driver.Row
+	ctx  context.Context
+	read *cachedRead
+	key  string
+}
+
+func (r *recordingRow) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		r.read.store(r.ctx, r.key, &cachedResult{Rows: [][]json.RawMessage{}})
+	case err == nil:
+		if row, _, ok := encodeScanned(dest); ok {
+			r.read.store(r.ctx, r.key, &cachedResult{Rows: [][]json.RawMessage{row}})
+		}
+	}
+	return err
+}
+
+// replayRows serves cached rows.
+type replayRows struct {
+	res *cachedResult
+	i   int
+}
+
+func (r *replayRows) Next() bool {
+	r.i++
+	return r.i < len(r.res.Rows)
+}
+
+func (r *replayRows) Scan(dest ...any) error {
+	if r.i < 0 || r.i >= len(r.res.Rows) {
+		return fmt.Errorf("clickhouse: result cache: scan without a row")
+	}
+	return decodeScanned(r.res.Rows[r.i], dest)
+}
+
+func (r *replayRows) ScanStruct(any) error {
+	return fmt.Errorf("clickhouse: result cache: ScanStruct is not supported")
+}
+
+func (r *replayRows) Totals(...any) error {
+	return fmt.Errorf("clickhouse: result cache: Totals is not supported")
+}
+
+// The opted-in reads scan by position, so column metadata is not kept.
+func (r *replayRows) ColumnTypes() []driver.ColumnType { return nil }
+func (r *replayRows) Columns() []string                { return nil }
+func (r *replayRows) Close() error                     { return nil }
+func (r *replayRows) Err() error                       { return nil }
+
+// replayRow serves a cached row.
+type replayRow struct {
+	res *cachedResult
+}
+
+func (r *replayRow) Err() error { return nil }
+
+func (r *replayRow) Scan(dest ...any) error {
+	if len(r.res.Rows) == 0 {
+		return sql.ErrNoRows
+	}
+	return decodeScanned(r.res.Rows[0], dest)
+}
+
+func (r *replayRow) ScanStruct(any) error {
+	return fmt.Errorf("clickhouse: result cache: ScanStruct is not supported")
+}
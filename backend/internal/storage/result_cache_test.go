@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// countingConn is a fakeConn that also counts its QueryRow calls.
+type countingConn struct {
+	*fakeConn
+	queryRows int
+}
+
+func (c *countingConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	c.queryRows++
+	return c.fakeConn.QueryRow(ctx, query, args...)
+}
+
+// memoryCache is a RedisCache holding its values in a map.
+type memoryCache struct {
+	RedisCache
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{values: map[string]string{}}
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return v, nil
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch v := value.(type) {
+	case []byte:
+		m.values[key] = string(v)
+	default:
+		m.values[key] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+func (m *memoryCache) TenantKey(tenantID, category, id string) string {
+	return "remedyiq:" + tenantID + ":" + category + ":" + id
+}
+
+// jobStore is a PostgresStore serving one job.
+type jobStore struct {
+	PostgresStore
+	job *domain.AnalysisJob
+}
+
+func (s *jobStore) GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, error) {
+	if s.job == nil || s.job.ID != jobID {
+		return nil, fmt.Errorf("postgres: job not found")
+	}
+	return s.job, nil
+}
+
+func newCachedClient(conn driver.Conn, jobs *jobStore, cfg ResultCacheConfig) (*ClickHouseClient, *memoryCache) {
+	c := &ClickHouseClient{conn: conn}
+	cache := newMemoryCache()
+	c.SetResultCache(cache, jobs, cfg)
+	return c, cache
+}
+
+func completeJob(completedAt time.Time) *domain.AnalysisJob {
+	return &domain.AnalysisJob{ID: uuid.New(), TenantID: uuid.New(), Status: domain.JobStatusComplete, CompletedAt: &completedAt}
+}
+
+func TestResultCache_SecondCallServedFromCache(t *testing.T) {
+	job := completeJob(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	conn := &countingConn{fakeConn: &fakeConn{rows: [][]any{{"Demo", uint64(40)}, {"Demo2", uint64(2)}}}}
+	c, _ := newCachedClient(conn, &jobStore{job: job}, ResultCacheConfig{})
+	ctx := context.Background()
+	tid, jid := job.TenantID.String(), job.ID.String()
+
+	first, err := c.GetAutocompleteValues(ctx, tid, jid, "user", "De", 10)
+	require.NoError(t, err)
+	second, err := c.GetAutocompleteValues(ctx, tid, jid, "user", "De", 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, conn.queries, "the second identical call does not reach ClickHouse")
+	assert.Equal(t, first, second)
+	assert.Equal(t, ResultCacheStats{Hits: 1, Misses: 1}, c.ResultCacheStats())
+
+	// Other arguments are another statement.
+	_, err = c.GetAutocompleteValues(ctx, tid, jid, "user", "Ad", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, conn.queries)
+}
+
+func TestResultCache_QueryRow(t *testing.T) {
+	job := completeJob(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	conn := &countingConn{fakeConn: &fakeConn{row: []any{uint64(10), uint64(4), uint64(2), []float64{50, 95, 99}}}}
+	c, _ := newCachedClient(conn, &jobStore{job: job}, ResultCacheConfig{})
+	ctx := context.Background()
+
+	first, err := c.GetDurationPercentile(ctx, job.TenantID.String(), job.ID.String(), domain.LogTypeAPI, "form", "HPD:Help Desk", 60)
+	require.NoError(t, err)
+	second, err := c.GetDurationPercentile(ctx, job.TenantID.String(), job.ID.String(), domain.LogTypeAPI, "form", "HPD:Help Desk", 60)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, conn.queryRows)
+	assert.Equal(t, first, second)
+}
+
+func TestResultCache_OversizedResultBypasses(t *testing.T) {
+	job := completeJob(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	rows := make([][]any, 50)
+	for i := range rows {
+		rows[i] = []any{strings.Repeat("u", 20) + fmt.Sprint(i), uint64(i)}
+	}
+	conn := &countingConn{fakeConn: &fakeConn{rows: rows}}
+	c, cache := newCachedClient(conn, &jobStore{job: job}, ResultCacheConfig{MaxEntryBytes: 512})
+	ctx := context.Background()
+
+	for range 2 {
+		values, err := c.GetAutocompleteValues(ctx, job.TenantID.String(), job.ID.String(), "user", "u", 50)
+		require.NoError(t, err)
+		assert.Len(t, values, 50, "an oversized result is still returned whole")
+	}
+
+	assert.Equal(t, 2, conn.queries, "an oversized result is not cached")
+	assert.Empty(t, cache.values)
+	assert.Equal(t, ResultCacheStats{Misses: 2, Oversized: 2}, c.ResultCacheStats())
+}
+
+func TestResultCache_IncompleteJobBypasses(t *testing.T) {
+	job := completeJob(time.Now())
+	job.Status, job.CompletedAt = domain.JobStatusParsing, nil
+	conn := &countingConn{fakeConn: &fakeConn{rows: [][]any{{"Demo", uint64(1)}}}}
+	c, cache := newCachedClient(conn, &jobStore{job: job}, ResultCacheConfig{})
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := c.GetAutocompleteValues(ctx, job.TenantID.String(), job.ID.String(), "user", "D", 10)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, conn.queries)
+	assert.Empty(t, cache.values)
+	assert.Equal(t, ResultCacheStats{Bypasses: 2}, c.ResultCacheStats())
+}
+
+func TestResultCache_ReprocessInvalidates(t *testing.T) {
+	job := completeJob(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	conn := &countingConn{fakeConn: &fakeConn{results: [][][]any{
+		{{"Demo", uint64(40)}},
+		{{"Demo", uint64(41)}},
+	}}}
+	c, _ := newCachedClient(conn, &jobStore{job: job}, ResultCacheConfig{})
+	ctx := context.Background()
+
+	before, err := c.GetAutocompleteValues(ctx, job.TenantID.String(), job.ID.String(), "user", "D", 10)
+	require.NoError(t, err)
+	reprocessed := job.CompletedAt.Add(time.Hour)
+	job.CompletedAt = &reprocessed
+	after, err := c.GetAutocompleteValues(ctx, job.TenantID.String(), job.ID.String(), "user", "D", 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, conn.queries)
+	assert.Equal(t, int64(40), before[0].Count)
+	assert.Equal(t, int64(41), after[0].Count)
+}
+
+func TestResultCache_KeyIgnoresWhitespaceAndArgOrder(t *testing.T) {
+	c, _ := newCachedClient(&fakeConn{}, &jobStore{}, ResultCacheConfig{})
+	read := &cachedRead{cache: c.results, tenantID: "t1", version: "j1@1"}
+
+	a := read.key("rows", "SELECT x\n\t FROM t WHERE a = @a", []any{driver.NamedValue{Name: "a", Value: 1}, driver.NamedValue{Name: "b", Value: "x"}})
+	b := read.key("rows", "SELECT x FROM t  WHERE a = @a", []any{driver.NamedValue{Name: "b", Value: "x"}, driver.NamedValue{Name: "a", Value: 1}})
+	assert.Equal(t, a, b)
+	assert.True(t, strings.HasPrefix(a, "remedyiq:t1:"+resultCacheCategory+":"))
+
+	assert.NotEqual(t, a, read.key("rows", "SELECT x FROM t WHERE a = @a", []any{driver.NamedValue{Name: "a", Value: "1"}, driver.NamedValue{Name: "b", Value: "x"}}), "argument types count")
+	assert.NotEqual(t, a, read.key("row", "SELECT x FROM t WHERE a = @a", []any{driver.NamedValue{Name: "a", Value: 1}, driver.NamedValue{Name: "b", Value: "x"}}))
+	other := &cachedRead{cache: c.results, tenantID: "t1", version: "j1@2"}
+	assert.NotEqual(t, a, other.key("rows", "SELECT x FROM t WHERE a = @a", []any{driver.NamedValue{Name: "a", Value: 1}, driver.NamedValue{Name: "b", Value: "x"}}))
+}
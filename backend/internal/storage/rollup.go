@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// rollupTable holds the entries of every job summed per minute, log type
+// and queue; see migrations/clickhouse/006_minute_rollup.sql. The time
+// series, histogram, focus metrics, queue load and error onset read it
+// instead of log_entries whenever they bucket by a minute or more.
+const rollupTable = "log_rollup_minute"
+
+// rollupIndex remembers the jobs found to have a rollup, so that reads only
+// look for one once per job. Jobs without one are looked up again on every
+// read: the worker may build it in the meantime.
+type rollupIndex struct {
+	jobs sync.Map // "tenantID/jobID" -> struct{}
+}
+
+func newRollupIndex() *rollupIndex {
+	return &rollupIndex{}
+}
+
+func rollupKey(tenantID, jobID string) string {
+	return tenantID + "/" + jobID
+}
+
+// BuildJobRollup replaces the rollup rows of a job with the sums of its
+// stored entries. It runs once ingestion is done, sample weights included,
+// and again whenever the job is processed anew or its rows are copied to
+// another cluster; a materialized view would miss the weight resets, which
+// are mutations rather than inserts.
+func (c *ClickHouseClient) BuildJobRollup(ctx context.Context, tenantID, jobID string) error {
+	assertCanonicalIDs(tenantID, jobID)
+	args := []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	}
+	exists, err := c.queryRollupExists(ctx, tenantID, jobID)
+	if err != nil {
+		return fmt.Errorf("clickhouse: build rollup: %w", err)
+	}
+	if exists {
+		if err := c.conn.Exec(ctx, `
+			ALTER TABLE `+rollupTable+`
+			DELETE WHERE tenant_id = @tenantID AND job_id = @jobID
+			SETTINGS mutations_sync = 1
+		`, args...); err != nil {
+			return fmt.Errorf("clickhouse: clear rollup: %w", err)
+		}
+	}
+	if err := c.conn.Exec(ctx, `
+		INSERT INTO `+rollupTable+` (
+			tenant_id, job_id, minute, log_type, queue,
+			entries, errors, duration_ms_weighted, queue_time_ms_weighted, max_sample_weight,
+			threaded_entries, busy_ms, first_ts, last_ts, p95_duration_ms, threads
+		)
+		SELECT
+			tenant_id,
+			job_id,
+			toStartOfMinute(timestamp) AS minute,
+			log_type,
+			queue,
+			sum(sample_weight),
+			sumIf(sample_weight, success = false),
+			sum(duration_ms * sample_weight),
+			sum(queue_time_ms * sample_weight),
+			max(sample_weight),
+			countIf(thread_id != ''),
+			sumIf(duration_ms, thread_id != ''),
+			min(timestamp),
+			max(timestamp),
+			quantileExactWeightedState(0.95)(duration_ms, sample_weight),
+			uniqExactStateIf(thread_id, thread_id != '')
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+		GROUP BY tenant_id, job_id, minute, log_type, queue
+	`, args...); err != nil {
+		return fmt.Errorf("clickhouse: build rollup: %w", err)
+	}
+	if c.rollups != nil {
+		c.rollups.jobs.Store(rollupKey(tenantID, jobID), struct{}{})
+	}
+	return nil
+}
+
+// hasRollup reports whether reads of a job can use its rollup. Jobs
+// ingested before the rollup existed have none and are read from
+// log_entries, as are all jobs of a client without a rollup index. A
+// failed lookup falls back to log_entries too.
+func (c *ClickHouseClient) hasRollup(ctx context.Context, tenantID, jobID string) bool {
+	if c.rollups == nil {
+		return false
+	}
+	key := rollupKey(tenantID, jobID)
+	if _, ok := c.rollups.jobs.Load(key); ok {
+		return true
+	}
+	exists, err := c.queryRollupExists(ctx, tenantID, jobID)
+	if err != nil {
+		slog.Debug("rollup lookup failed, reading log entries", "tenant_id", tenantID, "job_id", jobID, "error", err)
+		return false
+	}
+	if exists {
+		c.rollups.jobs.Store(key, struct{}{})
+	}
+	return exists
+}
+
+func (c *ClickHouseClient) queryRollupExists(ctx context.Context, tenantID, jobID string) (bool, error) {
+	var one uint8
+	err := c.conn.QueryRow(ctx, `
+		SELECT 1 FROM `+rollupTable+`
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+		LIMIT 1
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("rollup lookup: %w", err)
+	}
+	return true, nil
+}
+
+// forgetRollup drops a job from the rollup index once its rows are deleted.
+func (c *ClickHouseClient) forgetRollup(tenantID, jobID string) {
+	if c.rollups != nil {
+		c.rollups.jobs.Delete(rollupKey(tenantID, jobID))
+	}
+}
+
+// rollupSpan returns the first and last entry time of a job from its
+// rollup.
+func (c *ClickHouseClient) rollupSpan(ctx context.Context, tenantID, jobID string) (time.Time, time.Time, error) {
+	var first, last time.Time
+	if err := c.conn.QueryRow(ctx, `
+		SELECT min(first_ts), max(last_ts)
+		FROM `+rollupTable+`
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	).Scan(&first, &last); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("rollup span: %w", err)
+	}
+	return first, last, nil
+}
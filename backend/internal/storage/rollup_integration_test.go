@@ -0,0 +1,199 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insertRollupFixture generates rows entries for a job spread evenly over
+// span, with a mix of log types, queues, threads and sample weights and an
+// error burst in the last third of the capture.
+func insertRollupFixture(t testing.TB, client *ClickHouseClient, tenantID, jobID string, start time.Time, span time.Duration, rows int) {
+	t.Helper()
+	err := client.conn.Exec(context.Background(), `
+		INSERT INTO log_entries (
+			tenant_id, job_id, line_number, timestamp, log_type, queue, thread_id,
+			form, duration_ms, queue_time_ms, success, sample_weight
+		)
+		SELECT
+			@tenantID,
+			@jobID,
+			toUInt32(number + 1),
+			toDateTime64(@start, 3) + toIntervalMillisecond(intDiv(number * @spanMS, @rows)),
+			['API', 'SQL', 'FLTR', 'ESCL'][number % 4 + 1],
+			['Fast', 'List', 'Admin', ''][intHash32(number) % 4 + 1],
+			if(number % 7 = 0, '', toString(intHash32(number * 13) % 16)),
+			['HPD:Help Desk', 'CHG:Infrastructure Change', ''][number % 3 + 1],
+			toUInt32(intHash32(number * 3) % 2000),
+			toUInt32(intHash32(number * 5) % 300),
+			NOT (intHash32(number * 11) % 50 = 0 OR (number * 3 > @rows * 2 AND intHash32(number * 17) % 8 = 0)),
+			if(number % 10 < 3, 5, 1)
+		FROM numbers(@rows)
+	`,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("start", start.UTC().Format("2006-01-02 15:04:05")),
+		clickhouse.Named("spanMS", span.Milliseconds()),
+		clickhouse.Named("rows", rows),
+	)
+	require.NoError(t, err, "insert rollup fixture")
+}
+
+func TestClickHouse_RollupMatchesEntries(t *testing.T) {
+	client := setupClickHouse(t)
+	ctx := context.Background()
+
+	tenantID := "test-tenant-rollup"
+	jobID := fmt.Sprintf("test-job-rollup-%d", time.Now().UnixNano())
+	start := time.Now().UTC().Truncate(time.Hour).Add(-24 * time.Hour)
+	span := 6 * time.Hour
+	insertRollupFixture(t, client, tenantID, jobID, start, span, 50000)
+	t.Cleanup(func() { _ = client.DeleteJobEntries(ctx, tenantID, jobID) })
+
+	require.NoError(t, client.BuildJobRollup(ctx, tenantID, jobID))
+	raw := &ClickHouseClient{conn: client.conn}
+	require.True(t, client.hasRollup(ctx, tenantID, jobID))
+
+	t.Run("time series", func(t *testing.T) {
+		want, err := raw.queryTimeSeries(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		got, err := client.queryTimeSeries(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		require.Len(t, got, len(want))
+		for i := range want {
+			assert.InDelta(t, want[i].AvgDurationMS, got[i].AvgDurationMS, 1e-6)
+			got[i].AvgDurationMS = want[i].AvgDurationMS
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		want, err := raw.GetHistogramData(ctx, tenantID, jobID, start, start.Add(span))
+		require.NoError(t, err)
+		got, err := client.GetHistogramData(ctx, tenantID, jobID, start, start.Add(span))
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("focus metrics", func(t *testing.T) {
+		want, err := raw.GetFocusMetrics(ctx, tenantID, jobID, start, start.Add(span))
+		require.NoError(t, err)
+		got, err := client.GetFocusMetrics(ctx, tenantID, jobID, start, start.Add(span))
+		require.NoError(t, err)
+		require.Len(t, got.Buckets, len(want.Buckets))
+		for i := range want.Buckets {
+			assert.InDelta(t, want.Buckets[i].AvgQueueMS, got.Buckets[i].AvgQueueMS, 1e-6)
+			got.Buckets[i].AvgQueueMS = want.Buckets[i].AvgQueueMS
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("queue load", func(t *testing.T) {
+		want, err := raw.GetQueueLoad(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		got, err := client.GetQueueLoad(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("error onset", func(t *testing.T) {
+		want, err := raw.GetErrorOnset(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		got, err := client.GetErrorOnset(ctx, tenantID, jobID)
+		require.NoError(t, err)
+
+		assert.Equal(t, want.TotalEntries, got.TotalEntries)
+		assert.Equal(t, want.TotalErrors, got.TotalErrors)
+		assert.Equal(t, want.FirstError, got.FirstError)
+		assert.Zero(t, got.BucketMS%60000, "whole minutes")
+		require.Len(t, got.Thresholds, len(want.Thresholds))
+		for i := range want.Thresholds {
+			if want.Thresholds[i].CrossedAt == nil {
+				continue
+			}
+			require.NotNil(t, got.Thresholds[i].CrossedAt, "threshold %v", want.Thresholds[i].Threshold)
+			drift := got.Thresholds[i].CrossedAt.Sub(want.Thresholds[i].CrossedAt.Time)
+			assert.LessOrEqual(t, drift.Abs(), time.Duration(got.BucketMS)*time.Millisecond,
+				"threshold %v crossed within a bucket", want.Thresholds[i].Threshold)
+		}
+	})
+
+	t.Run("rebuild is idempotent", func(t *testing.T) {
+		before, err := client.queryTimeSeries(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		require.NoError(t, client.BuildJobRollup(ctx, tenantID, jobID))
+		after, err := client.queryTimeSeries(ctx, tenantID, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+}
+
+// BenchmarkRollupQueries compares the dashboard and analytics reads of a
+// large job on log_entries and on its rollup. ROLLUP_BENCH_ROWS sets the
+// number of entries (default 5,000,000).
+//
+//	go test -tags integration -run '^$' -bench RollupQueries ./internal/storage/
+func BenchmarkRollupQueries(b *testing.B) {
+	rows := 5_000_000
+	if v, err := strconv.Atoi(os.Getenv("ROLLUP_BENCH_ROWS")); err == nil && v > 0 {
+		rows = v
+	}
+	ctx := context.Background()
+	client, err := NewClickHouseClient(ctx, clickhouseDSN())
+	require.NoError(b, err, "failed to connect to ClickHouse")
+	defer client.Close()
+
+	tenantID := "bench-tenant-rollup"
+	jobID := fmt.Sprintf("bench-job-rollup-%d", time.Now().UnixNano())
+	start := time.Now().UTC().Truncate(time.Hour).Add(-48 * time.Hour)
+	span := 24 * time.Hour
+	insertRollupFixture(b, client, tenantID, jobID, start, span, rows)
+	defer func() { _ = client.DeleteJobEntries(ctx, tenantID, jobID) }()
+	require.NoError(b, client.BuildJobRollup(ctx, tenantID, jobID))
+
+	readers := map[string]*ClickHouseClient{
+		"raw":    {conn: client.conn},
+		"rollup": client,
+	}
+	for _, name := range []string{"raw", "rollup"} {
+		c := readers[name]
+		b.Run(name+"/time_series", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := c.queryTimeSeries(ctx, tenantID, jobID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/histogram", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := c.GetHistogramData(ctx, tenantID, jobID, start, start.Add(span)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/queue_load", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := c.GetQueueLoad(ctx, tenantID, jobID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/error_onset", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := c.GetErrorOnset(ctx, tenantID, jobID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
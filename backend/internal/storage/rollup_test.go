@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noRowsConn answers every QueryRow with sql.ErrNoRows.
+type noRowsConn struct {
+	*fakeConn
+}
+
+type noRow struct{ driver.Row }
+
+func (noRow) Scan(dest ...any) error { return sql.ErrNoRows }
+func (noRow) Err() error             { return sql.ErrNoRows }
+
+func (c noRowsConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return noRow{}
+}
+
+func rollupClient(conn driver.Conn, jobs ...string) *ClickHouseClient {
+	c := &ClickHouseClient{conn: conn, rollups: newRollupIndex()}
+	for _, job := range jobs {
+		c.rollups.jobs.Store(rollupKey(testTenantID, job), struct{}{})
+	}
+	return c
+}
+
+func TestBuildJobRollup(t *testing.T) {
+	t.Run("first build", func(t *testing.T) {
+		conn := &fakeConn{}
+		c := rollupClient(noRowsConn{conn})
+		require.NoError(t, c.BuildJobRollup(context.Background(), testTenantID, testJobID))
+
+		require.Len(t, conn.execs, 1, "nothing to clear")
+		assert.Contains(t, conn.execs[0], "INSERT INTO log_rollup_minute")
+		assert.Contains(t, conn.execs[0], "toStartOfMinute(timestamp) AS minute")
+		assert.Contains(t, conn.execs[0], "quantileExactWeightedState(0.95)(duration_ms, sample_weight)")
+		assert.Contains(t, conn.execs[0], "uniqExactStateIf(thread_id, thread_id != '')")
+		assert.True(t, c.hasRollup(context.Background(), testTenantID, testJobID), "remembered without a lookup")
+	})
+
+	t.Run("rebuild replaces the rows", func(t *testing.T) {
+		conn := &fakeConn{row: []any{uint8(1)}}
+		require.NoError(t, rollupClient(conn).BuildJobRollup(context.Background(), testTenantID, testJobID))
+
+		require.Len(t, conn.execs, 2)
+		assert.Contains(t, conn.execs[0], "ALTER TABLE log_rollup_minute")
+		assert.Contains(t, conn.execs[0], "mutations_sync = 1")
+		assert.Contains(t, conn.execs[1], "INSERT INTO log_rollup_minute")
+	})
+}
+
+func TestHasRollup(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, (&ClickHouseClient{conn: &fakeConn{}}).hasRollup(ctx, testTenantID, testJobID), "no index, no rollup reads")
+
+	c := rollupClient(noRowsConn{&fakeConn{}})
+	assert.False(t, c.hasRollup(ctx, testTenantID, testJobID))
+	_, remembered := c.rollups.jobs.Load(rollupKey(testTenantID, testJobID))
+	assert.False(t, remembered, "looked up again next time")
+
+	c = rollupClient(&fakeConn{row: []any{uint8(1)}})
+	assert.True(t, c.hasRollup(ctx, testTenantID, testJobID))
+	_, remembered = c.rollups.jobs.Load(rollupKey(testTenantID, testJobID))
+	assert.True(t, remembered)
+}
+
+func TestDeleteJobEntries_DeletesRollup(t *testing.T) {
+	conn := &fakeConn{}
+	c := rollupClient(conn, testJobID)
+	require.NoError(t, c.DeleteJobEntries(context.Background(), testTenantID, testJobID))
+
+	require.Len(t, conn.execs, 3)
+	assert.Contains(t, conn.execs[2], "ALTER TABLE log_rollup_minute")
+	_, remembered := c.rollups.jobs.Load(rollupKey(testTenantID, testJobID))
+	assert.False(t, remembered)
+}
+
+func TestRollupReads(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+
+	t.Run("minute granularity reads the rollup", func(t *testing.T) {
+		conn := &fakeConn{row: []any{uint32(1)}}
+		c := rollupClient(conn, testJobID)
+
+		_, err := c.GetHistogramData(ctx, testTenantID, testJobID, start, start.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Contains(t, conn.lastQuery, "FROM log_rollup_minute")
+		assert.Contains(t, conn.lastQuery, "sum(entries) AS cnt")
+
+		_, err = c.GetFocusMetrics(ctx, testTenantID, testJobID, start, start.Add(6*time.Hour))
+		require.NoError(t, err)
+		assert.Contains(t, conn.lastQuery, "quantileExactWeightedMerge(0.95)(p95_duration_ms)")
+
+		_, err = c.queryTimeSeries(ctx, testTenantID, testJobID)
+		require.NoError(t, err)
+		assert.Contains(t, conn.lastQuery, "FROM log_rollup_minute")
+
+		_, err = c.GetQueueLoad(ctx, testTenantID, testJobID)
+		require.NoError(t, err)
+		assert.Contains(t, conn.lastQuery, "uniqExactMerge(threads)")
+	})
+
+	t.Run("finer zoom reads the entries", func(t *testing.T) {
+		conn := &fakeConn{row: []any{uint32(1)}}
+		c := rollupClient(conn, testJobID)
+
+		_, err := c.GetHistogramData(ctx, testTenantID, testJobID, start, start.Add(10*time.Minute))
+		require.NoError(t, err)
+		assert.Contains(t, conn.lastQuery, "FROM log_entries")
+		assert.NotContains(t, conn.lastQuery, "log_rollup_minute")
+	})
+
+	t.Run("jobs without a rollup read the entries", func(t *testing.T) {
+		conn := &fakeConn{row: []any{uint32(1)}}
+		c := &ClickHouseClient{conn: conn}
+
+		_, err := c.GetHistogramData(ctx, testTenantID, testJobID, start, start.Add(time.Hour))
+		require.NoError(t, err)
+		assert.NotContains(t, conn.lastQuery, "log_rollup_minute")
+
+		_, err = c.GetQueueLoad(ctx, testTenantID, testJobID)
+		require.NoError(t, err)
+		assert.NotContains(t, conn.lastQuery, "log_rollup_minute")
+	})
+}
+
+func TestErrorOnsetBucketMS(t *testing.T) {
+	start := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		span time.Duration
+		want int64
+	}{
+		{0, 1000},
+		{time.Minute, 1000},
+		{4 * time.Hour, 60001},
+		{4*time.Hour - time.Millisecond, 60000},
+		{24 * time.Hour, 360001},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, errorOnsetBucketMS(start, start.Add(tt.span)), "span %s", tt.span)
+	}
+}
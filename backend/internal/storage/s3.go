@@ -2,13 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"path"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // S3Client wraps the AWS S3 SDK client for object storage operations.
@@ -114,6 +119,47 @@ func (s *S3Client) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// PresignGetURL returns a time-limited URL that downloads the object at key
+// without further authentication. The URL is signed locally; no request is
+// made to S3.
+func (s *S3Client) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("s3: presign %q: %w", key, err)
+	}
+
+	return req.URL, nil
+}
+
+// Exists reports whether an object is stored at key.
+func (s *S3Client) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return true, nil
+	}
+	var notFound *types.NotFound
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &notFound) || (errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound) {
+		return false, nil
+	}
+	return false, fmt.Errorf("s3: head %q: %w", key, err)
+}
+
+// CheckBucket verifies that the bucket exists and is accessible with the
+// configured credentials.
+func (s *S3Client) CheckBucket(ctx context.Context) error {
+	if _, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)}); err != nil {
+		return fmt.Errorf("s3: head bucket %q: %w", s.bucket, err)
+	}
+	return nil
+}
+
 // GenerateKey builds a tenant-prefixed S3 object key.
 // Format: tenants/{tenantID}/jobs/{jobID}/{filename}
 func (s *S3Client) GenerateKey(tenantID, jobID, filename string) string {
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // ---------------------------------------------------------------------------
@@ -259,3 +263,21 @@ func TestNewS3Client_ValidBucketCreatesClient(t *testing.T) {
 	assert.NotNil(t, client)
 	assert.Equal(t, "valid-bucket", client.Bucket())
 }
+
+// ---------------------------------------------------------------------------
+// PresignGetURL
+// ---------------------------------------------------------------------------
+
+func TestPresignGetURL(t *testing.T) {
+	// Presigning is a local operation, so a client pointed at an unreachable
+	// endpoint with bucket verification skipped is sufficient.
+	s, err := NewS3Client(context.Background(), "http://localhost:1", "access", "secret", "exports-bucket", false, true)
+	require.NoError(t, err)
+
+	url, err := s.PresignGetURL(context.Background(), "tenants/t1/exports/e1.csv.gz", 15*time.Minute)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(url, "http://localhost:1/exports-bucket/tenants/t1/exports/e1.csv.gz?"), url)
+	assert.Contains(t, url, "X-Amz-Expires=900")
+	assert.Contains(t, url, "X-Amz-Signature=")
+}
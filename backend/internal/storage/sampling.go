@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// sampleEstimate returns the estimate label of analytics over a job's
+// entries, from the largest sample weight among them.
+func (c *ClickHouseClient) sampleEstimate(ctx context.Context, tenantID, jobID string) (domain.Estimate, error) {
+	query := `
+		SELECT max(sample_weight)
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID
+	`
+	if c.hasRollup(ctx, tenantID, jobID) {
+		query = `
+			SELECT max(max_sample_weight)
+			FROM ` + rollupTable + `
+			WHERE tenant_id = @tenantID AND job_id = @jobID
+		`
+	}
+	var maxWeight uint32
+	if err := c.conn.QueryRow(ctx, query,
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	).Scan(&maxWeight); err != nil {
+		return domain.Estimate{}, fmt.Errorf("clickhouse: sample weight: %w", err)
+	}
+	return domain.NewEstimate(maxWeight), nil
+}
+
+// ResetSampleWeights makes the sampled entries of a job within windows
+// stand for themselves again, once the rest of the windows' entries have
+// been ingested. The mutation is waited for, so that analytics run next
+// see the new weights.
+func (c *ClickHouseClient) ResetSampleWeights(ctx context.Context, tenantID, jobID string, windows []domain.HotWindow) error {
+	assertCanonicalIDs(tenantID, jobID)
+	if len(windows) == 0 {
+		return nil
+	}
+	args := []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	}
+	conds := make([]string, len(windows))
+	for i, w := range windows {
+		start, end := fmt.Sprintf("windowStart%d", i), fmt.Sprintf("windowEnd%d", i)
+		conds[i] = fmt.Sprintf("timestamp BETWEEN toDateTime64(@%s, 3) AND toDateTime64(@%s, 3)", start, end)
+		args = append(args,
+			clickhouse.Named(start, w.Start.UTC().Format("2006-01-02 15:04:05.000")),
+			clickhouse.Named(end, w.End.UTC().Format("2006-01-02 15:04:05.000")),
+		)
+	}
+	if err := c.conn.Exec(ctx, `
+		ALTER TABLE log_entries
+		UPDATE sample_weight = 1
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND sample_weight > 1
+		  AND (`+strings.Join(conds, " OR ")+`)
+		SETTINGS mutations_sync = 1
+	`, args...); err != nil {
+		return fmt.Errorf("clickhouse: reset sample weights: %w", err)
+	}
+	return nil
+}
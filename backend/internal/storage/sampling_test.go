@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestResetSampleWeights(t *testing.T) {
+	start := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	windows := []domain.HotWindow{
+		{Start: domain.NewTimestamp(start), End: domain.NewTimestamp(start.Add(10 * time.Minute))},
+		{Start: domain.NewTimestamp(start.Add(time.Hour)), End: domain.NewTimestamp(start.Add(70 * time.Minute))},
+	}
+	conn := &fakeConn{}
+	require.NoError(t, (&ClickHouseClient{conn: conn}).ResetSampleWeights(context.Background(), testTenantID, testJobID, windows))
+
+	require.Len(t, conn.execs, 1)
+	q := conn.execs[0]
+	assert.Contains(t, q, "UPDATE sample_weight = 1")
+	assert.Contains(t, q, "sample_weight > 1")
+	assert.Contains(t, q, "@windowStart0")
+	assert.Contains(t, q, "@windowEnd1")
+	assert.Contains(t, q, "mutations_sync = 1")
+	assert.Len(t, conn.execArgs[0], 6)
+
+	conn = &fakeConn{}
+	require.NoError(t, (&ClickHouseClient{conn: conn}).ResetSampleWeights(context.Background(), testTenantID, testJobID, nil))
+	assert.Empty(t, conn.execs, "no windows, no mutation")
+}
+
+func TestGetHistogramData_WeightedEstimate(t *testing.T) {
+	bucket := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	conn := &fakeConn{
+		rows: [][]any{{bucket, "API", uint64(120)}, {bucket, "SQL", uint64(30)}},
+		row:  []any{uint32(10)},
+	}
+	resp, err := (&ClickHouseClient{conn: conn}).GetHistogramData(context.Background(), testTenantID, testJobID, bucket, bucket.Add(time.Hour))
+	require.NoError(t, err)
+
+	assert.Contains(t, conn.lastQuery, "sum(sample_weight) AS cnt")
+	require.Len(t, resp.Buckets, 1)
+	assert.Equal(t, int64(150), resp.Buckets[0].Counts.Total)
+	assert.Equal(t, domain.Estimate{Estimated: true, SampleRate: 10}, resp.Estimate)
+
+	conn.row = []any{uint32(1)}
+	resp, err = (&ClickHouseClient{conn: conn}).GetHistogramData(context.Background(), testTenantID, testJobID, bucket, bucket.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, resp.Estimated, "unsampled analytics are exact")
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// PostgresSchemaVersion is the number of the newest migration of
+// migrations/, and ClickHouseSchemaVersion that of migrations/clickhouse/.
+// A new migration raises its version and adds its marker below.
+const (
+	PostgresSchemaVersion   = 49
+	ClickHouseSchemaVersion = 10
+)
+
+// SchemaStatus is how far the migrations of a store have been applied.
+// Version is the newest migration found applied, 0 when there is no
+// schema at all; Missing lists the migrations up to Expected that are not.
+type SchemaStatus struct {
+	Version  int   `json:"version"`
+	Expected int   `json:"expected"`
+	Missing  []int `json:"missing,omitempty"`
+}
+
+// Current reports whether every migration has been applied.
+func (s SchemaStatus) Current() bool {
+	return len(s.Missing) == 0 && s.Version >= s.Expected
+}
+
+// schemaMarker is an object a migration creates: a column of a table in
+// Postgres, a table or a fragment of its definition in ClickHouse. The
+// migrations are applied by hand, without a table recording them, so
+// their markers tell which are.
+type schemaMarker struct {
+	version int
+	table   string
+	column  string
+}
+
+// postgresSchemaMarkers has a marker of each migration but 014 and 018,
+// which only replace a constraint.
+var postgresSchemaMarkers = []schemaMarker{
+	{1, "analysis_jobs", "status"},
+	{2, "search_history", "id"},
+	{3, "conversations", "id"},
+	{4, "analysis_jobs", "cpu_time_ms"},
+	{5, "search_exports", "id"},
+	{6, "analysis_jobs", "log_format"},
+	{7, "analysis_jobs", "violation_count"},
+	{8, "digest_subscriptions", "tenant_id"},
+	{9, "analysis_jobs", "clock_skew"},
+	{10, "tenants", "retention_class"},
+	{11, "analysis_jobs", "api_legend"},
+	{12, "analysis_jobs", "investigation_version"},
+	{13, "health_profiles", "tenant_id"},
+	{15, "analysis_jobs", "section_presence"},
+	{16, "tenant_usage", "tenant_id"},
+	{17, "analysis_jobs", "parse_diagnostics"},
+	{19, "analysis_jobs", "deleted_at"},
+	{20, "analysis_jobs", "error_onset"},
+	{21, "analysis_jobs", "file_integrity"},
+	{22, "tenant_vocabulary", "tenant_id"},
+	{23, "analysis_jobs", "priority"},
+	{24, "support_access_grants", "id"},
+	{25, "analysis_jobs", "restarts"},
+	{26, "analysis_jobs", "thread_counts"},
+	{27, "ingestion_filter_rules", "id"},
+	{28, "job_events", "id"},
+	{29, "analysis_jobs", "sampling"},
+	{30, "analysis_links", "id"},
+	{31, "local_users", "id"},
+	{32, "tenant_migrations", "id"},
+	{33, "analysis_jobs", "data_quality"},
+	{34, "analysis_jobs", "focus_window"},
+	{35, "tenants", "region"},
+	{36, "user_preferences", "tenant_id"},
+	{37, "worker_heartbeats", "worker_id"},
+	{38, "server_settings", "version"},
+	{39, "log_files", "source_type"},
+	{40, "idempotency_keys", "tenant_id"},
+	{41, "background_tasks", "name"},
+	{42, "tenants", "sandbox"},
+	{43, "job_checkpoints", "job_id"},
+	{44, "analysis_jobs", "estimate"},
+	{45, "search_exports", "provenance"},
+	{46, "analysis_jobs", "capture_drift"},
+	{47, "investigation_workspaces", "state"},
+	{48, "tenants", "sql_redaction"},
+	{49, "analysis_jobs", "cancel_requested_at"},
+}
+
+// clickHouseSchemaMarkers are matched against the definitions of the
+// tables; an empty column only requires the table.
+var clickHouseSchemaMarkers = []schemaMarker{
+	{1, "log_entries", ""},
+	{2, "log_entries", "retention_class"},
+	{3, "log_entries", "client_ip"},
+	{4, "log_entries", "is_noise"},
+	{5, "log_entries", "sample_weight"},
+	{6, "log_rollup_minute", ""},
+	{7, "log_entries", "'GC' = 6"},
+	{8, "log_entries", "raw_text_truncated"},
+	{9, "log_entries", "idx_raw_text_tokens"},
+	{10, "log_entries", "non_replicated_deduplication_window"},
+}
+
+// schemaStatus is the status of the migrations up to expected given which
+// of their markers are present.
+func schemaStatus(markers []schemaMarker, expected int, present func(schemaMarker) bool) SchemaStatus {
+	status := SchemaStatus{Expected: expected}
+	for _, m := range markers {
+		if present(m) {
+			status.Version = max(status.Version, m.version)
+		} else {
+			status.Missing = append(status.Missing, m.version)
+		}
+	}
+	return status
+}
+
+// markerTables returns the tables the markers are on.
+func markerTables(markers []schemaMarker) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, m := range markers {
+		if !seen[m.table] {
+			seen[m.table] = true
+			tables = append(tables, m.table)
+		}
+	}
+	return tables
+}
+
+// SchemaStatus reports which of the migrations of migrations/ have been
+// applied to the database.
+func (p *PostgresClient) SchemaStatus(ctx context.Context) (SchemaStatus, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ANY($1)
+	`, markerTables(postgresSchemaMarkers))
+	if err != nil {
+		return SchemaStatus{}, fmt.Errorf("postgres: schema status: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return SchemaStatus{}, fmt.Errorf("postgres: schema status scan: %w", err)
+		}
+		columns[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return SchemaStatus{}, fmt.Errorf("postgres: schema status: %w", err)
+	}
+	return schemaStatus(postgresSchemaMarkers, PostgresSchemaVersion, func(m schemaMarker) bool {
+		return columns[m.table+"."+m.column]
+	}), nil
+}
+
+// SchemaStatus reports which of the migrations of migrations/clickhouse/
+// have been applied to the database of the primary.
+func (c *ClickHouseClient) SchemaStatus(ctx context.Context) (SchemaStatus, error) {
+	rows, err := c.conn.Query(ctx, `
+		SELECT name, create_table_query FROM system.tables
+		WHERE database = currentDatabase() AND name IN (@tables)
+	`, clickhouse.Named("tables", markerTables(clickHouseSchemaMarkers)))
+	if err != nil {
+		return SchemaStatus{}, fmt.Errorf("clickhouse: schema status: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := make(map[string]string)
+	for rows.Next() {
+		var name, definition string
+		if err := rows.Scan(&name, &definition); err != nil {
+			return SchemaStatus{}, fmt.Errorf("clickhouse: schema status scan: %w", err)
+		}
+		definitions[name] = definition
+	}
+	if err := rows.Err(); err != nil {
+		return SchemaStatus{}, fmt.Errorf("clickhouse: schema status: %w", err)
+	}
+	return schemaStatus(clickHouseSchemaMarkers, ClickHouseSchemaVersion, func(m schemaMarker) bool {
+		definition, ok := definitions[m.table]
+		return ok && strings.Contains(definition, m.column)
+	}), nil
+}
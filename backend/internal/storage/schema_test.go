@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickHouseSchemaStatus(t *testing.T) {
+	current := "CREATE TABLE remedyiq.log_entries (`log_type` Enum8('API' = 1, 'SQL' = 2, 'FLTR' = 3, 'ESCL' = 4, 'HTTP' = 5, 'GC' = 6), " +
+		"`retention_class` String, `client_ip` String, `is_noise` Bool, `sample_weight` UInt32, `raw_text_truncated` Bool, " +
+		"INDEX idx_raw_text_tokens raw_text TYPE tokenbf_v1(32768, 3, 0)) ENGINE = MergeTree " +
+		"SETTINGS non_replicated_deduplication_window = 1000"
+
+	t.Run("current", func(t *testing.T) {
+		conn := &fakeConn{rows: [][]any{{"log_entries", current}, {"log_rollup_minute", "CREATE TABLE remedyiq.log_rollup_minute"}}}
+		status, err := (&ClickHouseClient{conn: conn}).SchemaStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, SchemaStatus{Version: ClickHouseSchemaVersion, Expected: ClickHouseSchemaVersion}, status)
+		assert.True(t, status.Current())
+	})
+
+	t.Run("behind", func(t *testing.T) {
+		conn := &fakeConn{rows: [][]any{{"log_entries", "CREATE TABLE remedyiq.log_entries (`log_type` Enum8('API' = 1), `retention_class` String, `client_ip` String)"}}}
+		status, err := (&ClickHouseClient{conn: conn}).SchemaStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 3, status.Version)
+		assert.Equal(t, []int{4, 5, 6, 7, 8, 9, 10}, status.Missing)
+		assert.False(t, status.Current())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		status, err := (&ClickHouseClient{conn: &fakeConn{}}).SchemaStatus(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, status.Version)
+		assert.Len(t, status.Missing, ClickHouseSchemaVersion)
+	})
+}
+
+func TestPostgresSchemaMarkers(t *testing.T) {
+	last := 0
+	for _, m := range postgresSchemaMarkers {
+		assert.Greater(t, m.version, last, "markers are in migration order")
+		last = m.version
+	}
+	assert.Equal(t, PostgresSchemaVersion, last, "the newest migration has a marker")
+}
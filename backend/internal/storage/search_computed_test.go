@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
+)
+
+// searchRow is a scanned search entry row followed by computed values.
+func searchRow(entryID string, durationMS uint32, computed ...any) []any {
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	row := []any{
+		testTenantID, testJobID, entryID, uint32(1), uint16(1),
+		ts, ts, "API",
+		"", "", "",
+		"", "Demo",
+		durationMS, uint32(0), true,
+		"GE", "HPD:Help Desk", "", "",
+		"", "",
+		"", uint8(0), "", "",
+		"", "", time.Time{}, uint32(0), false,
+		"", "", false, false,
+	}
+	return append(row, computed...)
+}
+
+func TestSearchEntries_ComputedColumns(t *testing.T) {
+	compiled, err := search.CompileComputed([]search.ComputedColumn{
+		{Name: "total_ms", Expr: "duration_ms + queue_time_ms"},
+		{Name: "is_slow", Expr: "duration_ms > 2000"},
+		{Name: "prefix", Expr: "substr(form, 1, position(form, ':') - 1)"},
+	})
+	require.NoError(t, err)
+	conn := &fakeConn{
+		row: []any{uint64(2)},
+		rows: [][]any{
+			searchRow("e1", 2500, float64(2600), uint8(1), "HPD"),
+			searchRow("e2", 100, math.Inf(1), uint8(0), "HPD"),
+		},
+	}
+	c := &ClickHouseClient{conn: conn}
+
+	res, err := c.SearchEntries(context.Background(), testTenantID, testJobID, SearchQuery{SortBy: "total_ms", SortOrder: "asc", Computed: compiled})
+	require.NoError(t, err)
+
+	assert.Contains(t, conn.lastQuery, "(toFloat64(duration_ms) + toFloat64(queue_time_ms)) AS computed_0")
+	assert.Contains(t, conn.lastQuery, "toUInt8((toFloat64(duration_ms) > toFloat64(@computed_1_0))) AS computed_1")
+	assert.Contains(t, conn.lastQuery, "ORDER BY computed_0 ASC")
+	assert.Len(t, conn.lastArgs, 2+1+3, "scope, is_slow threshold and the prefix literals")
+
+	require.Len(t, res.Entries, 2)
+	require.Len(t, res.Computed, 2)
+	assert.Equal(t, map[string]any{"total_ms": float64(2600), "is_slow": true, "prefix": "HPD"}, res.Computed[0])
+	assert.Nil(t, res.Computed[1]["total_ms"], "an overflowed number is null")
+	assert.Equal(t, false, res.Computed[1]["is_slow"])
+}
+
+func TestSearchEntries_WithoutComputedColumns(t *testing.T) {
+	conn := &fakeConn{row: []any{uint64(1)}, rows: [][]any{searchRow("e1", 10)}}
+	c := &ClickHouseClient{conn: conn}
+
+	res, err := c.SearchEntries(context.Background(), testTenantID, testJobID, SearchQuery{SortBy: "total_ms"})
+	require.NoError(t, err)
+
+	assert.NotContains(t, conn.lastQuery, "computed_")
+	assert.Contains(t, conn.lastQuery, "ORDER BY timestamp DESC", "an unknown sort column falls back to timestamp")
+	assert.Nil(t, res.Computed)
+}
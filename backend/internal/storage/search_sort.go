@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// sortExpressions are the ORDER BY expressions of the columns searches sort
+// by. Strings sort on their lowercased value, so that "alice" comes before
+// "Bob"; the values returned are left as stored.
+var sortExpressions = map[string]string{
+	"timestamp":   "timestamp",
+	"duration_ms": "duration_ms",
+	"line_number": "line_number",
+	"user":        "lower(user)",
+	"log_type":    "lower(log_type)",
+	"queue":       "lower(queue)",
+	"form":        "lower(form)",
+}
+
+// searchTiebreakers end the order of every search, so that entries tying
+// on the requested columns come back in the same order each time.
+var searchTiebreakers = []string{"timestamp", "line_number", "entry_id"}
+
+// IsSortField reports whether searches can sort by the entry column name.
+// Computed columns can be sorted by as well.
+func IsSortField(name string) bool {
+	_, ok := sortExpressions[name]
+	return ok
+}
+
+// EffectiveSort returns the order SearchEntries returns entries in: SortBy,
+// or timestamp when it names no sortable column, then SecondarySortBy when
+// it names one, then the tiebreakers timestamp, line_number and entry_id in
+// the direction of SortBy. Each column appears once.
+func (q SearchQuery) EffectiveSort() []domain.SortKey {
+	primary := q.SortBy
+	if !q.sortable(primary) {
+		primary = "timestamp"
+	}
+	order := sortOrder(q.SortOrder)
+	keys := []domain.SortKey{{Field: primary, Order: order}}
+	if q.sortable(q.SecondarySortBy) && q.SecondarySortBy != primary {
+		keys = append(keys, domain.SortKey{Field: q.SecondarySortBy, Order: sortOrder(q.SecondarySortOrder)})
+	}
+	for _, field := range searchTiebreakers {
+		if !containsSortField(keys, field) {
+			keys = append(keys, domain.SortKey{Field: field, Order: order})
+		}
+	}
+	return keys
+}
+
+// orderBy returns the ORDER BY list of the effective sort of q.
+func (q SearchQuery) orderBy() string {
+	keys := q.EffectiveSort()
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s %s", q.sortExpression(key.Field), strings.ToUpper(key.Order))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortable reports whether name is an entry column or a computed column
+// of q that can be sorted by.
+func (q SearchQuery) sortable(name string) bool {
+	if IsSortField(name) {
+		return true
+	}
+	for _, col := range q.Computed {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortExpression returns the ORDER BY expression of a column: the alias of
+// a computed column, or the expression of an entry column.
+func (q SearchQuery) sortExpression(field string) string {
+	for i, col := range q.Computed {
+		if col.Name == field {
+			return computedAlias(i)
+		}
+	}
+	if expr, ok := sortExpressions[field]; ok {
+		return expr
+	}
+	return field
+}
+
+// sortOrder returns the direction of a sort_order parameter, descending
+// unless it asks for ascending.
+func sortOrder(s string) string {
+	if strings.EqualFold(s, "asc") {
+		return "asc"
+	}
+	return "desc"
+}
+
+func containsSortField(keys []domain.SortKey, field string) bool {
+	for _, key := range keys {
+		if key.Field == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
+)
+
+func TestSearchQuery_OrderBy(t *testing.T) {
+	tests := []struct {
+		name string
+		q    SearchQuery
+		want string
+	}{
+		{"default", SearchQuery{},
+			"timestamp DESC, line_number DESC, entry_id DESC"},
+		{"timestamp ascending", SearchQuery{SortBy: "timestamp", SortOrder: "ASC"},
+			"timestamp ASC, line_number ASC, entry_id ASC"},
+		{"duration", SearchQuery{SortBy: "duration_ms", SortOrder: "asc"},
+			"duration_ms ASC, timestamp ASC, line_number ASC, entry_id ASC"},
+		{"line number", SearchQuery{SortBy: "line_number"},
+			"line_number DESC, timestamp DESC, entry_id DESC"},
+		{"user is case-insensitive", SearchQuery{SortBy: "user", SortOrder: "asc"},
+			"lower(user) ASC, timestamp ASC, line_number ASC, entry_id ASC"},
+		{"log type", SearchQuery{SortBy: "log_type"},
+			"lower(log_type) DESC, timestamp DESC, line_number DESC, entry_id DESC"},
+		{"queue", SearchQuery{SortBy: "queue", SortOrder: "asc"},
+			"lower(queue) ASC, timestamp ASC, line_number ASC, entry_id ASC"},
+		{"form", SearchQuery{SortBy: "form", SortOrder: "desc"},
+			"lower(form) DESC, timestamp DESC, line_number DESC, entry_id DESC"},
+		{"secondary", SearchQuery{SortBy: "user", SortOrder: "asc", SecondarySortBy: "duration_ms", SecondarySortOrder: "desc"},
+			"lower(user) ASC, duration_ms DESC, timestamp ASC, line_number ASC, entry_id ASC"},
+		{"secondary tiebreaker column", SearchQuery{SortBy: "form", SecondarySortBy: "line_number", SecondarySortOrder: "asc"},
+			"lower(form) DESC, line_number ASC, timestamp DESC, entry_id DESC"},
+		{"secondary same as primary", SearchQuery{SortBy: "user", SecondarySortBy: "user", SecondarySortOrder: "asc"},
+			"lower(user) DESC, timestamp DESC, line_number DESC, entry_id DESC"},
+		{"unknown secondary ignored", SearchQuery{SortBy: "queue", SecondarySortBy: "raw_text"},
+			"lower(queue) DESC, timestamp DESC, line_number DESC, entry_id DESC"},
+		{"unknown primary falls back", SearchQuery{SortBy: "timestamp; DROP TABLE", SortOrder: "asc"},
+			"timestamp ASC, line_number ASC, entry_id ASC"},
+		{"column names are case-sensitive", SearchQuery{SortBy: "USER"},
+			"timestamp DESC, line_number DESC, entry_id DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.q.orderBy())
+		})
+	}
+}
+
+func TestSearchQuery_OrderByComputed(t *testing.T) {
+	compiled, err := search.CompileComputed([]search.ComputedColumn{
+		{Name: "total_ms", Expr: "duration_ms + queue_time_ms"},
+		{Name: "prefix", Expr: "substr(form, 1, 3)"},
+	})
+	require.NoError(t, err)
+
+	q := SearchQuery{SortBy: "form", SortOrder: "asc", SecondarySortBy: "total_ms", Computed: compiled}
+	assert.Equal(t, "lower(form) ASC, computed_0 DESC, timestamp ASC, line_number ASC, entry_id ASC", q.orderBy())
+
+	q = SearchQuery{SortBy: "prefix", Computed: compiled}
+	assert.Equal(t, "computed_1 DESC, timestamp DESC, line_number DESC, entry_id DESC", q.orderBy())
+}
+
+func TestSearchQuery_EffectiveSort(t *testing.T) {
+	q := SearchQuery{SortBy: "user", SortOrder: "asc", SecondarySortBy: "queue"}
+	assert.Equal(t, []domain.SortKey{
+		{Field: "user", Order: "asc"},
+		{Field: "queue", Order: "desc"},
+		{Field: "timestamp", Order: "asc"},
+		{Field: "line_number", Order: "asc"},
+		{Field: "entry_id", Order: "asc"},
+	}, q.EffectiveSort())
+}
+
+func TestSearchEntries_OrderBy(t *testing.T) {
+	conn := &fakeConn{row: []any{uint64(1)}, rows: [][]any{searchRow("e1", 10)}}
+	c := &ClickHouseClient{conn: conn}
+
+	res, err := c.SearchEntries(context.Background(), testTenantID, testJobID, SearchQuery{SortBy: "user", SortOrder: "asc", SecondarySortBy: "form"})
+	require.NoError(t, err)
+
+	assert.Contains(t, conn.lastQuery, "ORDER BY lower(user) ASC, lower(form) DESC, timestamp ASC, line_number ASC, entry_id ASC\n")
+	assert.Contains(t, conn.lastQuery, "queue, user,", "the values are selected as stored")
+	require.Len(t, res.Entries, 1)
+	assert.Equal(t, "Demo", res.Entries[0].User)
+}
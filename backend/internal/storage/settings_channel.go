@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// settingsChannel is the pub/sub channel changes of the dynamic server
+// settings are announced on. The settings cover every tenant, so it is not
+// a tenant key.
+const settingsChannel = "remedyiq:settings:changed"
+
+// PublishSettingsChanged announces that version of the dynamic server
+// settings was stored, so that every API and worker replica reloads them.
+func (r *RedisClient) PublishSettingsChanged(ctx context.Context, version int) error {
+	if err := r.client.Publish(ctx, settingsChannel, version).Err(); err != nil {
+		return fmt.Errorf("redis: publish settings change: %w", err)
+	}
+	return nil
+}
+
+// SubscribeSettingsChanges returns the versions of the dynamic server
+// settings announced from now on, until ctx is done. The subscription is
+// in place when it returns; it is re-established after Redis connection
+// losses, and changes announced meanwhile are missed.
+func (r *RedisClient) SubscribeSettingsChanges(ctx context.Context) (<-chan int, error) {
+	sub := r.client.Subscribe(ctx, settingsChannel)
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, fmt.Errorf("redis: subscribe to settings changes: %w", err)
+	}
+
+	versions := make(chan int, 1)
+	go func() {
+		defer close(versions)
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				v, err := strconv.Atoi(msg.Payload)
+				if err != nil {
+					slog.Warn("ignoring malformed settings change", "payload", msg.Payload)
+					continue
+				}
+				select {
+				case versions <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return versions, nil
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/logparser"
+)
+
+const (
+	// sqlStatementPrefixChars is how much of a statement groups it with
+	// others, so that statements differing only past it count as one.
+	sqlStatementPrefixChars = 200
+
+	// maxSQLTableGroups caps the statement groups read for a table, the
+	// costliest first.
+	maxSQLTableGroups = 2000
+
+	maxSQLTopStatements    = 20
+	maxSQLFullScanSuspects = 20
+
+	// nonKeyScanMinAvgMS is the average duration from which a statement
+	// filtered on columns other than C1 is suspected of scanning its table.
+	// Faster ones are most likely served by an index.
+	nonKeyScanMinAvgMS = 100
+)
+
+// sqlOperations is the order of the operation breakdown.
+var sqlOperations = []string{
+	domain.SQLOperationSelect,
+	domain.SQLOperationInsert,
+	domain.SQLOperationUpdate,
+	domain.SQLOperationDelete,
+	domain.SQLOperationOther,
+}
+
+// GetSQLTableDrilldown breaks down the SQL load of a job on one table. The
+// statements are grouped on their first 200 characters in ClickHouse; the
+// operation of each group and whether it may scan the table are derived in
+// Go from its statements.
+func (c *ClickHouseClient) GetSQLTableDrilldown(ctx context.Context, tenantID, jobID, table string) (*domain.SQLTableDrilldown, error) {
+	ctx = replicaRead(ctx)
+	where := "tenant_id = @tenantID AND job_id = @jobID AND log_type = 'SQL' AND sql_table = @table"
+	args := []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("table", table),
+	}
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			leftUTF8(sql_statement, %d)     AS statement,
+			count()                         AS cnt,
+			countIf(success = false)        AS errors,
+			toInt64(sum(duration_ms))       AS total_ms,
+			toInt64(max(duration_ms))       AS max_ms,
+			groupUniqArray(3)(sql_statement) AS examples
+		FROM log_entries
+		WHERE %s
+		GROUP BY statement
+		ORDER BY total_ms DESC, cnt DESC, statement
+		LIMIT %d
+	`, sqlStatementPrefixChars, where, maxSQLTableGroups), args...)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: sql table statements: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []domain.SQLStatementGroup
+	for rows.Next() {
+		var g domain.SQLStatementGroup
+		var cnt, errs uint64
+		if err := rows.Scan(&g.Statement, &cnt, &errs, &g.TotalDurationMS, &g.MaxDurationMS, &g.Examples); err != nil {
+			return nil, fmt.Errorf("clickhouse: sql table statements scan: %w", err)
+		}
+		g.Count, g.ErrorCount = int64(cnt), int64(errs)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: sql table statements rows: %w", err)
+	}
+
+	hourRows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			toHour(timestamp, 'UTC')  AS hour,
+			count()                   AS cnt,
+			toInt64(sum(duration_ms)) AS total_ms
+		FROM log_entries
+		WHERE %s
+		GROUP BY hour
+		ORDER BY hour
+	`, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: sql table hourly load: %w", err)
+	}
+	defer hourRows.Close()
+
+	var hours []domain.SQLHourLoad
+	for hourRows.Next() {
+		var h domain.SQLHourLoad
+		var hour uint8
+		var cnt uint64
+		if err := hourRows.Scan(&hour, &cnt, &h.TotalDurationMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: sql table hourly load scan: %w", err)
+		}
+		h.Hour, h.Count = int(hour), int64(cnt)
+		hours = append(hours, h)
+	}
+	if err := hourRows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: sql table hourly load rows: %w", err)
+	}
+
+	d := &domain.SQLTableDrilldown{JobID: jobID, Table: table}
+	buildSQLTableDrilldown(d, groups, hours)
+	return d, nil
+}
+
+// buildSQLTableDrilldown fills d from the statement groups of its table,
+// costliest first, and its load per hour of the day. The totals come from
+// the hours, so that groups past maxSQLTableGroups still count: their load
+// goes to OTHER and d is marked truncated.
+func buildSQLTableDrilldown(d *domain.SQLTableDrilldown, groups []domain.SQLStatementGroup, hours []domain.SQLHourLoad) {
+	d.HourlyLoad = make([]domain.SQLHourLoad, 24)
+	for i := range d.HourlyLoad {
+		d.HourlyLoad[i].Hour = i
+	}
+	d.TotalCount, d.TotalDurationMS = 0, 0
+	for _, h := range hours {
+		if h.Hour < 0 || h.Hour >= 24 {
+			continue
+		}
+		d.HourlyLoad[h.Hour] = h
+		d.TotalCount += h.Count
+		d.TotalDurationMS += h.TotalDurationMS
+	}
+
+	byOp := make(map[string]*domain.SQLOperationStat, len(sqlOperations))
+	d.Operations = make([]domain.SQLOperationStat, len(sqlOperations))
+	for i, op := range sqlOperations {
+		d.Operations[i].Operation = op
+		byOp[op] = &d.Operations[i]
+	}
+
+	d.TopStatements = []domain.SQLStatementGroup{}
+	d.FullScanSuspects = []domain.SQLStatementGroup{}
+	var groupedCount, groupedMS int64
+	for _, g := range groups {
+		if g.Examples == nil {
+			g.Examples = []string{}
+		}
+		if g.Count > 0 {
+			g.AvgDurationMS = float64(g.TotalDurationMS) / float64(g.Count)
+		}
+		// The statements of a group may only differ past the prefix, and
+		// the full ones are what the heuristics need.
+		full := g.Statement
+		if len(g.Examples) > 0 {
+			full = g.Examples[0]
+		}
+		g.Operation = logparser.SQLOperation(full)
+		g.ScanReason = logparser.SQLScanSuspicion(full)
+		if g.ScanReason == domain.SQLScanNonKeyFilter && g.AvgDurationMS < nonKeyScanMinAvgMS {
+			g.ScanReason = ""
+		}
+
+		stat := byOp[g.Operation]
+		stat.Count += g.Count
+		stat.TotalDurationMS += g.TotalDurationMS
+		groupedCount += g.Count
+		groupedMS += g.TotalDurationMS
+
+		if len(d.TopStatements) < maxSQLTopStatements {
+			d.TopStatements = append(d.TopStatements, g)
+		}
+		if g.ScanReason != "" && len(d.FullScanSuspects) < maxSQLFullScanSuspects {
+			d.FullScanSuspects = append(d.FullScanSuspects, g)
+		}
+	}
+
+	if rest := d.TotalCount - groupedCount; rest > 0 {
+		d.Truncated = true
+		other := byOp[domain.SQLOperationOther]
+		other.Count += rest
+		other.TotalDurationMS += max(d.TotalDurationMS-groupedMS, 0)
+	}
+	for i := range d.Operations {
+		if op := &d.Operations[i]; op.Count > 0 {
+			op.AvgDurationMS = float64(op.TotalDurationMS) / float64(op.Count)
+		}
+	}
+}
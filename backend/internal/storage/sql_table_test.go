@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func sqlGroup(stmt string, count, totalMS int64) domain.SQLStatementGroup {
+	return domain.SQLStatementGroup{Statement: stmt, Count: count, TotalDurationMS: totalMS, MaxDurationMS: totalMS, Examples: []string{stmt}}
+}
+
+func operation(t *testing.T, d *domain.SQLTableDrilldown, op string) domain.SQLOperationStat {
+	t.Helper()
+	for _, s := range d.Operations {
+		if s.Operation == op {
+			return s
+		}
+	}
+	t.Fatalf("no %s operation", op)
+	return domain.SQLOperationStat{}
+}
+
+func TestBuildSQLTableDrilldown(t *testing.T) {
+	t.Run("breakdown and suspects", func(t *testing.T) {
+		groups := []domain.SQLStatementGroup{
+			sqlGroup(`SELECT T4381.C1 FROM T4381 WHERE (T4381.C536870913 LIKE N'%x%')`, 10, 5000),
+			sqlGroup(`SELECT T4381.C1 FROM T4381`, 2, 3000),
+			sqlGroup(`UPDATE T4381 SET C7 = 1 WHERE T4381.C1 = N'000000000003816'`, 40, 2000),
+			sqlGroup(`SELECT T4381.C1 FROM T4381 WHERE T4381.C7 = 0`, 100, 1000),
+			sqlGroup(`INSERT INTO T4381 (C1,C2) VALUES (N'000000000003817',N'Demo')`, 20, 400),
+			sqlGroup(`DELETE FROM T4381 WHERE T4381.C1 = N'000000000003816'`, 4, 40),
+		}
+		hours := []domain.SQLHourLoad{
+			{Hour: 9, Count: 100, TotalDurationMS: 6440},
+			{Hour: 14, Count: 76, TotalDurationMS: 5000},
+		}
+		d := &domain.SQLTableDrilldown{Table: "T4381"}
+		buildSQLTableDrilldown(d, groups, hours)
+
+		assert.Equal(t, int64(176), d.TotalCount)
+		assert.Equal(t, int64(11440), d.TotalDurationMS)
+		assert.False(t, d.Truncated)
+
+		require.Len(t, d.Operations, 5)
+		assert.Equal(t, domain.SQLOperationStat{Operation: "SELECT", Count: 112, TotalDurationMS: 9000, AvgDurationMS: 9000.0 / 112}, operation(t, d, "SELECT"))
+		assert.Equal(t, int64(40), operation(t, d, "UPDATE").Count)
+		assert.Equal(t, int64(20), operation(t, d, "INSERT").Count)
+		assert.Equal(t, int64(4), operation(t, d, "DELETE").Count)
+		assert.Zero(t, operation(t, d, "OTHER").Count)
+
+		require.Len(t, d.TopStatements, 6)
+		assert.Equal(t, 500.0, d.TopStatements[0].AvgDurationMS)
+		assert.Equal(t, "SELECT", d.TopStatements[0].Operation)
+
+		require.Len(t, d.FullScanSuspects, 2, "the fast non-key select is not suspected")
+		assert.Equal(t, domain.SQLScanNonKeyFilter, d.FullScanSuspects[0].ScanReason)
+		assert.Equal(t, domain.SQLScanNoWhere, d.FullScanSuspects[1].ScanReason)
+		assert.Equal(t, []string{`SELECT T4381.C1 FROM T4381`}, d.FullScanSuspects[1].Examples)
+
+		require.Len(t, d.HourlyLoad, 24)
+		assert.Equal(t, domain.SQLHourLoad{Hour: 9, Count: 100, TotalDurationMS: 6440}, d.HourlyLoad[9])
+		assert.Equal(t, domain.SQLHourLoad{Hour: 10}, d.HourlyLoad[10])
+	})
+
+	t.Run("heuristics use the full statement", func(t *testing.T) {
+		full := `SELECT T4381.C1 FROM T4381 WHERE T4381.C1 = N'000000000003816'`
+		g := sqlGroup(full[:30], 1, 500)
+		g.Examples = []string{full}
+		d := &domain.SQLTableDrilldown{}
+		buildSQLTableDrilldown(d, []domain.SQLStatementGroup{g}, []domain.SQLHourLoad{{Hour: 0, Count: 1, TotalDurationMS: 500}})
+		assert.Empty(t, d.FullScanSuspects, "the WHERE clause is past the grouped prefix")
+	})
+
+	t.Run("groups past the cap go to OTHER", func(t *testing.T) {
+		groups := []domain.SQLStatementGroup{sqlGroup(`SELECT T24.C1 FROM T24 WHERE T24.C1 = ?`, 10, 100)}
+		hours := []domain.SQLHourLoad{{Hour: 3, Count: 15, TotalDurationMS: 150}}
+		d := &domain.SQLTableDrilldown{}
+		buildSQLTableDrilldown(d, groups, hours)
+
+		assert.True(t, d.Truncated)
+		assert.Equal(t, domain.SQLOperationStat{Operation: "OTHER", Count: 5, TotalDurationMS: 50, AvgDurationMS: 10}, operation(t, d, "OTHER"))
+	})
+
+	t.Run("no statements", func(t *testing.T) {
+		d := &domain.SQLTableDrilldown{}
+		buildSQLTableDrilldown(d, nil, nil)
+		assert.Zero(t, d.TotalCount)
+		assert.NotNil(t, d.TopStatements)
+		assert.NotNil(t, d.FullScanSuspects)
+		assert.Len(t, d.HourlyLoad, 24)
+		assert.Len(t, d.Operations, 5)
+	})
+}
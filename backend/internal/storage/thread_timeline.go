@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// MaxThreadSegments caps the segments returned per thread. Threads with
+// more are flagged as truncated.
+const MaxThreadSegments = 500
+
+// timelineLogTypes is the order of the per-type counts of a segment row,
+// which is also the tie-break order for the dominant log type.
+var timelineLogTypes = []domain.LogType{
+	domain.LogTypeAPI, domain.LogTypeSQL, domain.LogTypeFilter, domain.LogTypeEscalation,
+}
+
+// threadSegmentRow is a segment as computed by ClickHouse, with its entry
+// count per log type so that merged segments keep an exact dominant type.
+type threadSegmentRow struct {
+	Start      time.Time
+	End        time.Time
+	EntryCount int64
+	ErrorCount int64
+	TypeCounts [4]int64 // in timelineLogTypes order
+}
+
+// GetThreadTimeline returns the busy segments of the busiest threads of a
+// job, or of the threads of q.Queue, within [q.From, q.To]. Entries on a
+// thread less than q.GapMS apart, measured from the end of the previous
+// call, are merged into one segment. ClickHouse does the merging with
+// window functions; the rows are merged again in Go so that segments stay
+// correct whatever the ordering ClickHouse used at the boundaries.
+func (c *ClickHouseClient) GetThreadTimeline(ctx context.Context, tenantID, jobID string, q domain.ThreadTimelineQuery) ([]domain.ThreadTimeline, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	queueFilter := ""
+	if q.Queue != "" {
+		queueFilter = "AND queue = @queue"
+	}
+	args := []any{
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+		clickhouse.Named("from", q.From),
+		clickhouse.Named("to", q.To),
+		clickhouse.Named("queue", q.Queue),
+		clickhouse.Named("gapMS", q.GapMS),
+	}
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			thread_id,
+			any(queue) AS queue,
+			count() AS total_entries,
+			countIf(success = false) AS total_errors,
+			toInt64(sum(duration_ms)) AS total_ms
+		FROM log_entries
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND thread_id != ''
+			AND timestamp BETWEEN @from AND @to %s
+		GROUP BY thread_id
+		ORDER BY total_ms DESC, total_entries DESC, thread_id
+		LIMIT %d
+	`, queueFilter, q.Limit), args...)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: thread timeline threads: %w", err)
+	}
+	defer rows.Close()
+
+	var threads []domain.ThreadTimeline
+	var threadIDs []string
+	for rows.Next() {
+		var t domain.ThreadTimeline
+		var entries, errs uint64
+		if err := rows.Scan(&t.ThreadID, &t.Queue, &entries, &errs, &t.TotalMS); err != nil {
+			return nil, fmt.Errorf("clickhouse: thread timeline threads scan: %w", err)
+		}
+		t.TotalEntries = int64(entries)
+		t.TotalErrors = int64(errs)
+		threads = append(threads, t)
+		threadIDs = append(threadIDs, t.ThreadID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: thread timeline threads rows: %w", err)
+	}
+	if len(threads) == 0 {
+		return []domain.ThreadTimeline{}, nil
+	}
+
+	// A segment starts at the first entry of a thread and wherever an entry
+	// begins at least gapMS after every earlier call on the thread ended.
+	// One extra segment per thread is read to detect truncation.
+	segRows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			thread_id,
+			min(timestamp) AS seg_start,
+			max(end_ts) AS seg_end,
+			count() AS entries,
+			countIf(success = false) AS errors,
+			countIf(log_type = 'API') AS api,
+			countIf(log_type = 'SQL') AS sql,
+			countIf(log_type = 'FLTR') AS fltr,
+			countIf(log_type = 'ESCL') AS escl
+		FROM (
+			SELECT
+				thread_id, timestamp, end_ts, success, log_type,
+				sum(is_start) OVER (PARTITION BY thread_id ORDER BY timestamp, file_number, line_number
+					ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS seg
+			FROM (
+				SELECT
+					thread_id, timestamp, file_number, line_number, success, log_type,
+					timestamp + toIntervalMillisecond(duration_ms) AS end_ts,
+					if(
+						row_number() OVER (PARTITION BY thread_id ORDER BY timestamp, file_number, line_number) = 1
+							OR dateDiff('millisecond',
+								max(timestamp + toIntervalMillisecond(duration_ms)) OVER (
+									PARTITION BY thread_id ORDER BY timestamp, file_number, line_number
+									ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING),
+								timestamp) >= @gapMS,
+						1, 0
+					) AS is_start
+				FROM log_entries
+				WHERE tenant_id = @tenantID AND job_id = @jobID AND has(@threads, thread_id)
+					AND timestamp BETWEEN @from AND @to
+			)
+		)
+		GROUP BY thread_id, seg
+		ORDER BY thread_id, seg
+		LIMIT %d BY thread_id
+	`, MaxThreadSegments+1), append(args, clickhouse.Named("threads", threadIDs))...)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: thread timeline segments: %w", err)
+	}
+	defer segRows.Close()
+
+	byThread := make(map[string][]threadSegmentRow, len(threads))
+	for segRows.Next() {
+		var thread string
+		var seg threadSegmentRow
+		var entries, errs uint64
+		var counts [4]uint64
+		if err := segRows.Scan(&thread, &seg.Start, &seg.End, &entries, &errs,
+			&counts[0], &counts[1], &counts[2], &counts[3]); err != nil {
+			return nil, fmt.Errorf("clickhouse: thread timeline segments scan: %w", err)
+		}
+		seg.EntryCount = int64(entries)
+		seg.ErrorCount = int64(errs)
+		for i, n := range counts {
+			seg.TypeCounts[i] = int64(n)
+		}
+		byThread[thread] = append(byThread[thread], seg)
+	}
+	if err := segRows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: thread timeline segments rows: %w", err)
+	}
+
+	gap := time.Duration(q.GapMS) * time.Millisecond
+	for i := range threads {
+		threads[i].Segments, threads[i].Truncated = mergeThreadSegments(byThread[threads[i].ThreadID], gap, MaxThreadSegments)
+	}
+	return threads, nil
+}
+
+// mergeThreadSegments sorts the segments of one thread, merges those that
+// overlap or are less than gap apart and caps the result at limit segments,
+// reporting whether anything was cut. More than limit input rows also
+// counts as truncated, since ClickHouse stops reading at limit+1.
+func mergeThreadSegments(rows []threadSegmentRow, gap time.Duration, limit int) ([]domain.ThreadSegment, bool) {
+	truncated := len(rows) > limit
+	sorted := append([]threadSegmentRow(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []threadSegmentRow
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.Start.Sub(merged[n-1].End) < gap {
+			last := &merged[n-1]
+			if r.End.After(last.End) {
+				last.End = r.End
+			}
+			last.EntryCount += r.EntryCount
+			last.ErrorCount += r.ErrorCount
+			for i := range last.TypeCounts {
+				last.TypeCounts[i] += r.TypeCounts[i]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	if len(merged) > limit {
+		merged = merged[:limit]
+		truncated = true
+	}
+
+	segments := make([]domain.ThreadSegment, 0, len(merged))
+	for _, r := range merged {
+		segments = append(segments, domain.ThreadSegment{
+			Start:           domain.NewTimestamp(r.Start),
+			End:             domain.NewTimestamp(r.End),
+			EntryCount:      r.EntryCount,
+			ErrorCount:      r.ErrorCount,
+			DominantLogType: dominantLogType(r.TypeCounts),
+		})
+	}
+	return segments, truncated
+}
+
+// dominantLogType returns the log type with the most entries, preferring
+// the earlier type of timelineLogTypes on a tie.
+func dominantLogType(counts [4]int64) domain.LogType {
+	best := 0
+	for i, n := range counts {
+		if n > counts[best] {
+			best = i
+		}
+	}
+	return timelineLogTypes[best]
+}
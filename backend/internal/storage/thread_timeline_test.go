@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var timelineStart = time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC)
+
+// segRow returns a segment of one entry of the given type starting at
+// offset and lasting dur.
+func segRow(offset, dur time.Duration, logType int, errors int64) threadSegmentRow {
+	r := threadSegmentRow{
+		Start:      timelineStart.Add(offset),
+		End:        timelineStart.Add(offset + dur),
+		EntryCount: 1,
+		ErrorCount: errors,
+	}
+	r.TypeCounts[logType] = 1
+	return r
+}
+
+func TestMergeThreadSegments(t *testing.T) {
+	const api, sql, fltr = 0, 1, 2
+
+	t.Run("no entries", func(t *testing.T) {
+		segs, truncated := mergeThreadSegments(nil, time.Second, 10)
+		assert.Empty(t, segs)
+		assert.False(t, truncated)
+	})
+
+	t.Run("single entry", func(t *testing.T) {
+		segs, truncated := mergeThreadSegments([]threadSegmentRow{segRow(0, 250*time.Millisecond, sql, 1)}, time.Second, 10)
+		require.Len(t, segs, 1)
+		assert.False(t, truncated)
+		assert.Equal(t, domain.ThreadSegment{
+			Start:           domain.NewTimestamp(timelineStart),
+			End:             domain.NewTimestamp(timelineStart.Add(250 * time.Millisecond)),
+			EntryCount:      1,
+			ErrorCount:      1,
+			DominantLogType: domain.LogTypeSQL,
+		}, segs[0])
+	})
+
+	t.Run("zero-duration entries", func(t *testing.T) {
+		segs, _ := mergeThreadSegments([]threadSegmentRow{
+			segRow(0, 0, api, 0),
+			segRow(500*time.Millisecond, 0, api, 0),
+			segRow(3*time.Second, 0, api, 0),
+		}, time.Second, 10)
+		require.Len(t, segs, 2)
+		assert.Equal(t, int64(2), segs[0].EntryCount)
+		assert.Equal(t, timelineStart.Add(500*time.Millisecond), segs[0].End.Time)
+		assert.Equal(t, segs[1].Start, segs[1].End)
+	})
+
+	t.Run("back-to-back entries merge", func(t *testing.T) {
+		segs, _ := mergeThreadSegments([]threadSegmentRow{
+			segRow(0, time.Second, api, 0),
+			segRow(time.Second, time.Second, sql, 0),
+			segRow(2*time.Second, time.Second, sql, 0),
+		}, time.Second, 10)
+		require.Len(t, segs, 1)
+		assert.Equal(t, timelineStart.Add(3*time.Second), segs[0].End.Time)
+		assert.Equal(t, int64(3), segs[0].EntryCount)
+		assert.Equal(t, domain.LogTypeSQL, segs[0].DominantLogType)
+	})
+
+	t.Run("gap equal to the threshold splits", func(t *testing.T) {
+		segs, _ := mergeThreadSegments([]threadSegmentRow{
+			segRow(0, time.Second, api, 0),
+			segRow(2*time.Second, time.Second, api, 0),
+		}, time.Second, 10)
+		assert.Len(t, segs, 2)
+	})
+
+	t.Run("a long call covers later entries", func(t *testing.T) {
+		segs, _ := mergeThreadSegments([]threadSegmentRow{
+			segRow(2*time.Second, 100*time.Millisecond, fltr, 0),
+			segRow(0, 10*time.Second, api, 0),
+			segRow(5*time.Second, 100*time.Millisecond, fltr, 0),
+		}, time.Second, 10)
+		require.Len(t, segs, 1)
+		assert.Equal(t, timelineStart, segs[0].Start.Time)
+		assert.Equal(t, timelineStart.Add(10*time.Second), segs[0].End.Time)
+		assert.Equal(t, domain.LogTypeFilter, segs[0].DominantLogType)
+	})
+
+	t.Run("dominant type ties prefer API", func(t *testing.T) {
+		segs, _ := mergeThreadSegments([]threadSegmentRow{
+			segRow(0, 0, sql, 0),
+			segRow(0, 0, api, 0),
+		}, time.Second, 10)
+		require.Len(t, segs, 1)
+		assert.Equal(t, domain.LogTypeAPI, segs[0].DominantLogType)
+	})
+
+	t.Run("capped with truncation flag", func(t *testing.T) {
+		var rows []threadSegmentRow
+		for i := 0; i < 5; i++ {
+			rows = append(rows, segRow(time.Duration(i)*10*time.Second, time.Second, api, 0))
+		}
+		segs, truncated := mergeThreadSegments(rows, time.Second, 3)
+		assert.Len(t, segs, 3)
+		assert.True(t, truncated)
+		assert.Equal(t, timelineStart.Add(20*time.Second), segs[2].Start.Time)
+	})
+
+	t.Run("more rows than the cap is truncated even after merging", func(t *testing.T) {
+		rows := []threadSegmentRow{
+			segRow(0, time.Second, api, 0),
+			segRow(1500*time.Millisecond, time.Second, api, 0),
+			segRow(3*time.Second, time.Second, api, 0),
+		}
+		segs, truncated := mergeThreadSegments(rows, time.Second, 2)
+		assert.Len(t, segs, 1)
+		assert.True(t, truncated)
+	})
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed token_bucket.lua
+var tokenBucketLua string
+
+var tokenBucketScript = redis.NewScript(tokenBucketLua)
+
+// TokenBucket is the shape of a token bucket: Burst tokens at most,
+// refilled at Rate tokens per second.
+type TokenBucket struct {
+	Rate  float64 `json:"rate_per_sec"`
+	Burst int     `json:"burst"`
+}
+
+// PerMinute returns a bucket refilled with n tokens per minute.
+func PerMinute(n, burst int) TokenBucket {
+	return TokenBucket{Rate: float64(n) / 60, Burst: burst}
+}
+
+// TokenBucketState is the state of a bucket after a take or a peek.
+// RetryAfter is set when a take was refused.
+type TokenBucketState struct {
+	Allowed    bool          `json:"allowed"`
+	Tokens     float64       `json:"tokens"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+// TakeToken takes one token from the bucket stored at key, creating it full
+// when missing. The bucket is updated atomically by a Lua script using the
+// Redis server clock, so the limit holds across API replicas.
+func (r *RedisClient) TakeToken(ctx context.Context, key string, bucket TokenBucket) (TokenBucketState, error) {
+	return r.runTokenBucket(ctx, key, bucket, 1)
+}
+
+// PeekToken returns the current state of the bucket stored at key without
+// taking a token.
+func (r *RedisClient) PeekToken(ctx context.Context, key string, bucket TokenBucket) (TokenBucketState, error) {
+	return r.runTokenBucket(ctx, key, bucket, 0)
+}
+
+func (r *RedisClient) runTokenBucket(ctx context.Context, key string, bucket TokenBucket, cost int) (TokenBucketState, error) {
+	if bucket.Rate <= 0 || bucket.Burst <= 0 {
+		return TokenBucketState{}, fmt.Errorf("redis: token bucket %q: rate and burst must be positive", key)
+	}
+	res, err := tokenBucketScript.Run(ctx, r.client, []string{key}, bucket.Rate, bucket.Burst, cost).Slice()
+	if err != nil {
+		return TokenBucketState{}, fmt.Errorf("redis: token bucket %q: %w", key, err)
+	}
+	if len(res) != 3 {
+		return TokenBucketState{}, fmt.Errorf("redis: token bucket %q: unexpected reply %v", key, res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	retryMS, _ := res[2].(int64)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return TokenBucketState{}, fmt.Errorf("redis: token bucket %q: tokens %q: %w", key, tokensStr, err)
+	}
+	return TokenBucketState{
+		Allowed:    allowed == 1,
+		Tokens:     tokens,
+		RetryAfter: time.Duration(retryMS) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,49 @@
+-- Token bucket rate limiter.
+--
+-- KEYS[1]  bucket key
+-- ARGV[1]  refill rate in tokens per second
+-- ARGV[2]  bucket capacity (burst)
+-- ARGV[3]  tokens to take; 0 only reads the bucket
+--
+-- Returns {allowed (0/1), tokens left (string), retry after in ms}.
+--
+-- The clock is the Redis server's, so that API replicas with drifting
+-- clocks share one notion of time.
+
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+local elapsed = now - ts
+if elapsed < 0 then
+	elapsed = 0
+end
+tokens = math.min(burst, tokens + elapsed * rate / 1000)
+
+local allowed = 1
+local retry_ms = 0
+if cost > 0 then
+	if tokens >= cost then
+		tokens = tokens - cost
+	else
+		allowed = 0
+		retry_ms = math.ceil((cost - tokens) * 1000 / rate)
+	end
+	redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', tostring(now))
+	redis.call('PEXPIRE', key, math.ceil(burst * 1000 / rate) + 1000)
+end
+
+-- Lua numbers are truncated to integers on the way back; keep the fraction.
+return {allowed, tostring(tokens), retry_ms}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMiniredisClient(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC))
+	client, err := NewRedisClient(context.Background(), "redis://"+mr.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client, mr
+}
+
+func TestTakeToken(t *testing.T) {
+	ctx := context.Background()
+	client, mr := newMiniredisClient(t)
+	bucket := PerMinute(60, 3) // one token per second
+
+	for i := 0; i < 3; i++ {
+		state, err := client.TakeToken(ctx, "rl", bucket)
+		require.NoError(t, err)
+		assert.True(t, state.Allowed, "take %d", i)
+		assert.InDelta(t, float64(2-i), state.Tokens, 0.001)
+	}
+
+	state, err := client.TakeToken(ctx, "rl", bucket)
+	require.NoError(t, err)
+	assert.False(t, state.Allowed)
+	assert.Equal(t, time.Second, state.RetryAfter)
+
+	// Half a token later the bucket is still short.
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 0, 500000000, time.UTC))
+	state, err = client.TakeToken(ctx, "rl", bucket)
+	require.NoError(t, err)
+	assert.False(t, state.Allowed)
+	assert.Equal(t, 500*time.Millisecond, state.RetryAfter)
+	assert.InDelta(t, 0.5, state.Tokens, 0.001)
+
+	mr.SetTime(time.Date(2026, 2, 3, 10, 0, 1, 0, time.UTC))
+	state, err = client.TakeToken(ctx, "rl", bucket)
+	require.NoError(t, err)
+	assert.True(t, state.Allowed)
+
+	// The refill never exceeds the burst.
+	mr.SetTime(time.Date(2026, 2, 3, 11, 0, 0, 0, time.UTC))
+	state, err = client.PeekToken(ctx, "rl", bucket)
+	require.NoError(t, err)
+	assert.InDelta(t, 3, state.Tokens, 0.001)
+
+	assert.True(t, mr.Exists("rl"))
+	assert.Greater(t, mr.TTL("rl"), time.Duration(0))
+}
+
+func TestPeekToken_DoesNotConsume(t *testing.T) {
+	ctx := context.Background()
+	client, mr := newMiniredisClient(t)
+	bucket := PerMinute(60, 2)
+
+	for i := 0; i < 3; i++ {
+		state, err := client.PeekToken(ctx, "rl", bucket)
+		require.NoError(t, err)
+		assert.True(t, state.Allowed)
+		assert.InDelta(t, 2, state.Tokens, 0.001)
+	}
+	assert.False(t, mr.Exists("rl"), "a peek must not create the bucket")
+}
+
+func TestTakeToken_InvalidBucket(t *testing.T) {
+	client, _ := newMiniredisClient(t)
+	_, err := client.TakeToken(context.Background(), "rl", TokenBucket{Rate: 0, Burst: 1})
+	assert.Error(t, err)
+}
+
+func TestTakeToken_RedisDown(t *testing.T) {
+	client, mr := newMiniredisClient(t)
+	mr.Close()
+	_, err := client.TakeToken(context.Background(), "rl", PerMinute(60, 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// traceRow is a log_entries row as selected by GetTraceEntriesStream.
+func traceRow(entryID string, ts time.Time) []any {
+	return []any{
+		testTenantID, testJobID, entryID, uint32(1), uint16(1),
+		ts, ts, "API",
+		"T001", "", "",
+		"", "",
+		uint32(5), uint32(0), true,
+		"GE", "HPD:Help Desk", "", "",
+		"", "",
+		"", uint8(0), "", "",
+		"", "", time.Time{}, uint32(0), false,
+		"", "",
+	}
+}
+
+func TestGetTraceEntriesStream(t *testing.T) {
+	base := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	var rows [][]any
+	for i := 0; i < 5; i++ {
+		rows = append(rows, traceRow(fmt.Sprintf("e%d", i), base.Add(time.Duration(i)*time.Millisecond)))
+	}
+
+	t.Run("all entries", func(t *testing.T) {
+		conn := &fakeConn{rows: rows}
+		entries, err := (&ClickHouseClient{conn: conn}).GetTraceEntries(context.Background(), testTenantID, testJobID, "T001")
+		require.NoError(t, err)
+		require.Len(t, entries, 5)
+		assert.Equal(t, "e4", entries[4].EntryID)
+		assert.Equal(t, domain.LogTypeAPI, entries[4].LogType)
+		assert.Nil(t, entries[4].ScheduledTime)
+		assert.Contains(t, conn.lastQuery, "ORDER BY timestamp ASC, entry_id ASC")
+		assert.NotContains(t, conn.lastQuery, "LIMIT")
+		assert.NotContains(t, conn.lastQuery, "@afterTimestamp")
+	})
+
+	t.Run("cursor and limit", func(t *testing.T) {
+		conn := &fakeConn{rows: rows}
+		q := TraceQuery{After: &TraceCursor{Timestamp: base, EntryID: "e0"}, Limit: 3}
+		err := (&ClickHouseClient{conn: conn}).GetTraceEntriesStream(context.Background(), testTenantID, testJobID, "T001", q,
+			func(domain.LogEntry) error { return nil })
+		require.NoError(t, err)
+		assert.Contains(t, conn.lastQuery, "(timestamp, entry_id) > (@afterTimestamp, @afterEntryID)")
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(conn.lastQuery), "LIMIT 3"))
+		assert.Contains(t, conn.lastArgs, clickhouse.Named("afterTimestamp", base))
+		assert.Contains(t, conn.lastArgs, clickhouse.Named("afterEntryID", "e0"))
+	})
+
+	t.Run("callback error stops the stream", func(t *testing.T) {
+		stop := errors.New("stop")
+		var seen []string
+		err := (&ClickHouseClient{conn: &fakeConn{rows: rows}}).GetTraceEntriesStream(context.Background(), testTenantID, testJobID, "T001", TraceQuery{},
+			func(e domain.LogEntry) error {
+				seen = append(seen, e.EntryID)
+				if len(seen) == 2 {
+					return stop
+				}
+				return nil
+			})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, []string{"e0", "e1"}, seen)
+	})
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// uploadSlotKeys returns the keys of a tenant's upload semaphore. It is run
+// by the same script as the query semaphore.
+func (r *RedisClient) uploadSlotKeys(tenantID string) []string {
+	return []string{r.TenantKey(tenantID, "uploads", ""), r.TenantKey(tenantID, "uploads", "info")}
+}
+
+// AcquireUploadSlot takes one of limit upload slots of the tenant for u,
+// reporting whether one was free. A limit of 0 or less always succeeds, so
+// that the upload is still listed. The slot is held until ReleaseUploadSlot
+// or the lease runs out; long uploads renew it with RenewUploadSlot.
+func (r *RedisClient) AcquireUploadSlot(ctx context.Context, u domain.InFlightUpload, limit int, lease time.Duration) (bool, error) {
+	if lease <= 0 {
+		return false, fmt.Errorf("redis: upload slot %s: lease must be positive", u.ID)
+	}
+	info, err := json.Marshal(u)
+	if err != nil {
+		return false, fmt.Errorf("redis: upload slot %s: marshal: %w", u.ID, err)
+	}
+	res, err := querySlotsScript.Run(ctx, r.client, r.uploadSlotKeys(u.TenantID),
+		u.ID, limit, lease.Milliseconds(), string(info)).Slice()
+	if err != nil {
+		return false, fmt.Errorf("redis: upload slot %s: %w", u.ID, err)
+	}
+	if len(res) != 2 {
+		return false, fmt.Errorf("redis: upload slot %s: unexpected reply %v", u.ID, res)
+	}
+	acquired, _ := res[0].(int64)
+	return acquired == 1, nil
+}
+
+// RenewUploadSlot extends the lease of the slot held by upload id to lease
+// from now. A slot that is no longer held is not taken again.
+func (r *RedisClient) RenewUploadSlot(ctx context.Context, tenantID, id string, lease time.Duration) error {
+	keys := r.uploadSlotKeys(tenantID)
+	now, err := r.client.Time(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("redis: renew upload slot %s: %w", id, err)
+	}
+	pipe := r.client.TxPipeline()
+	pipe.ZAddXX(ctx, keys[0], redis.Z{Score: float64(now.Add(lease).UnixMilli()), Member: id})
+	pipe.PExpire(ctx, keys[0], lease)
+	pipe.PExpire(ctx, keys[1], lease)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis: renew upload slot %s: %w", id, err)
+	}
+	return nil
+}
+
+// ReleaseUploadSlot frees the slot held by upload id. Releasing a slot that
+// is not held is not an error.
+func (r *RedisClient) ReleaseUploadSlot(ctx context.Context, tenantID, id string) error {
+	keys := r.uploadSlotKeys(tenantID)
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, keys[0], id)
+	pipe.HDel(ctx, keys[1], id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis: release upload slot %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListUploadSlots returns the uploads holding a slot of the tenant, oldest
+// first. Expired leases are left out.
+func (r *RedisClient) ListUploadSlots(ctx context.Context, tenantID string) ([]domain.InFlightUpload, error) {
+	infos, err := r.slotHolders(ctx, r.uploadSlotKeys(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("redis: list upload slots: %w", err)
+	}
+	uploads := make([]domain.InFlightUpload, 0, len(infos))
+	for _, s := range infos {
+		var u domain.InFlightUpload
+		if err := json.Unmarshal([]byte(s), &u); err != nil {
+			continue
+		}
+		uploads = append(uploads, u)
+	}
+	sort.SliceStable(uploads, func(i, j int) bool { return uploads[i].StartedAt.Before(uploads[j].StartedAt) })
+	return uploads, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestUploadSlots(t *testing.T) {
+	ctx := context.Background()
+	client, mr := newMiniredisClient(t)
+	start := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	mr.SetTime(start)
+	upload := func(id string) domain.InFlightUpload {
+		return domain.InFlightUpload{ID: id, TenantID: "t1", ContentLength: 4 << 30, StartedAt: start}
+	}
+
+	ok, err := client.AcquireUploadSlot(ctx, upload("u1"), 1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = client.AcquireUploadSlot(ctx, upload("u2"), 1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "the only slot is held")
+
+	queries, err := client.ListQuerySlots(ctx, "t1")
+	require.NoError(t, err)
+	assert.Empty(t, queries, "uploads do not take query slots")
+
+	// A renewed lease outlives the first one.
+	mr.SetTime(start.Add(50 * time.Second))
+	require.NoError(t, client.RenewUploadSlot(ctx, "t1", "u1", time.Minute))
+	mr.SetTime(start.Add(90 * time.Second))
+	uploads, err := client.ListUploadSlots(ctx, "t1")
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, int64(4<<30), uploads[0].ContentLength)
+
+	require.NoError(t, client.ReleaseUploadSlot(ctx, "t1", "u1"))
+	require.NoError(t, client.RenewUploadSlot(ctx, "t1", "u1", time.Minute), "renewing a released slot is harmless")
+	uploads, err = client.ListUploadSlots(ctx, "t1")
+	require.NoError(t, err)
+	assert.Empty(t, uploads, "a released slot is not taken again by its renewal")
+
+	ok, err = client.AcquireUploadSlot(ctx, upload("u2"), 1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
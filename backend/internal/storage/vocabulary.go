@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// MaxJobVocabularyValues caps the distinct values of one field read
+	// from an analysis for the tenant vocabulary; the most frequent win.
+	MaxJobVocabularyValues = 20000
+
+	// maxVocabularyValueLen is the length above which a value is not kept
+	// in the vocabulary. AR object names are at most 254 characters, so a
+	// longer value is a parsing artefact and would only bloat the index.
+	maxVocabularyValueLen = 1024
+)
+
+// GetJobVocabulary returns the distinct values of every vocabulary field
+// in a job's entries with their entry counts, most frequent first and at
+// most limit per field, keyed by field.
+func (c *ClickHouseClient) GetJobVocabulary(ctx context.Context, tenantID, jobID string, limit int) (map[string][]domain.AutocompleteValue, error) {
+	assertCanonicalIDs(tenantID, jobID)
+	ctx = replicaRead(ctx)
+	if limit <= 0 || limit > MaxJobVocabularyValues {
+		limit = MaxJobVocabularyValues
+	}
+
+	// The field names come from domain.VocabularyFields and are safe to
+	// interpolate as identifiers.
+	pairs := make([]string, len(domain.VocabularyFields))
+	for i, f := range domain.VocabularyFields {
+		pairs[i] = fmt.Sprintf("('%s', %s)", f, f)
+	}
+
+	rows, err := c.conn.Query(ctx, fmt.Sprintf(`
+		SELECT kv.1 AS field, kv.2 AS value, count() AS cnt
+		FROM log_entries
+		ARRAY JOIN [%s] AS kv
+		WHERE tenant_id = @tenantID AND job_id = @jobID AND kv.2 != ''
+		GROUP BY field, value
+		ORDER BY field, cnt DESC, value
+		LIMIT %d BY field
+	`, strings.Join(pairs, ", "), limit),
+		clickhouse.Named("tenantID", tenantID),
+		clickhouse.Named("jobID", jobID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: job vocabulary: %w", err)
+	}
+	defer rows.Close()
+
+	vocabulary := make(map[string][]domain.AutocompleteValue, len(domain.VocabularyFields))
+	for rows.Next() {
+		var field string
+		var v domain.AutocompleteValue
+		var cnt uint64
+		if err := rows.Scan(&field, &v.Value, &cnt); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan job vocabulary: %w", err)
+		}
+		v.Count = int64(cnt)
+		vocabulary[field] = append(vocabulary[field], v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("clickhouse: job vocabulary rows: %w", err)
+	}
+	return vocabulary, nil
+}
+
+// upsertVocabularySQL adds the values of one field seen in one job to the
+// tenant vocabulary in a single statement, whatever the number of values.
+// Running it again for the same job, as a retried job does, leaves the
+// counts unchanged.
+const upsertVocabularySQL = `
+	INSERT INTO tenant_vocabulary (tenant_id, field, value, occurrences, job_count, last_job_id, first_seen_at, last_seen_at)
+	SELECT $1, $2, v.value, v.occurrences, 1, $3, $6, $6
+	FROM unnest($4::text[], $5::bigint[]) AS v(value, occurrences)
+	ON CONFLICT (tenant_id, field, value) DO UPDATE
+	SET occurrences = tenant_vocabulary.occurrences +
+	        CASE WHEN tenant_vocabulary.last_job_id = EXCLUDED.last_job_id THEN 0 ELSE EXCLUDED.occurrences END,
+	    job_count = tenant_vocabulary.job_count +
+	        CASE WHEN tenant_vocabulary.last_job_id = EXCLUDED.last_job_id THEN 0 ELSE 1 END,
+	    last_job_id = EXCLUDED.last_job_id,
+	    last_seen_at = EXCLUDED.last_seen_at`
+
+// vocabularyBatch returns the values and counts bound to upsertVocabularySQL.
+// Values are trimmed; empty and overlong ones are dropped and duplicates are
+// merged, as one statement may not update the same row twice.
+func vocabularyBatch(values []domain.AutocompleteValue) ([]string, []int64) {
+	counts := make(map[string]int64, len(values))
+	for _, v := range values {
+		value := strings.TrimSpace(v.Value)
+		if value == "" || len(value) > maxVocabularyValueLen {
+			continue
+		}
+		counts[value] += v.Count
+	}
+
+	terms := make([]string, 0, len(counts))
+	for value := range counts {
+		terms = append(terms, value)
+	}
+	// A stable order keeps concurrent upserts of overlapping batches from
+	// locking rows in different orders.
+	sort.Strings(terms)
+	occurrences := make([]int64, len(terms))
+	for i, value := range terms {
+		occurrences[i] = counts[value]
+	}
+	return terms, occurrences
+}
+
+// UpsertVocabulary records the values of field seen in a job in the tenant
+// vocabulary with one statement, and returns the number of values recorded.
+func (p *PostgresClient) UpsertVocabulary(ctx context.Context, tenantID, jobID uuid.UUID, field string, values []domain.AutocompleteValue) (int, error) {
+	if !domain.IsVocabularyField(field) {
+		return 0, fmt.Errorf("postgres: unknown vocabulary field: %s", field)
+	}
+	terms, occurrences := vocabularyBatch(values)
+	if len(terms) == 0 {
+		return 0, nil
+	}
+	tag, err := p.pool.Exec(ctx, upsertVocabularySQL, tenantID, field, jobID, terms, occurrences, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("postgres: upsert vocabulary: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// evictVocabularySQL removes the values of a tenant unseen since $2, and the
+// least recently seen values of each field beyond the $3 most recent.
+const evictVocabularySQL = `
+	DELETE FROM tenant_vocabulary v
+	USING (
+		SELECT field, value,
+		       row_number() OVER (PARTITION BY field ORDER BY last_seen_at DESC, occurrences DESC, value) AS recency
+		FROM tenant_vocabulary
+		WHERE tenant_id = $1
+	) r
+	WHERE v.tenant_id = $1 AND v.field = r.field AND v.value = r.value
+	  AND (v.last_seen_at < $2 OR r.recency > $3)`
+
+// EvictVocabulary bounds the vocabulary of a tenant: values not seen since
+// unseenSince are removed, as are the least recently seen values of each
+// field beyond maxPerField. It returns the number of values removed.
+func (p *PostgresClient) EvictVocabulary(ctx context.Context, tenantID uuid.UUID, unseenSince time.Time, maxPerField int) (int64, error) {
+	tag, err := p.pool.Exec(ctx, evictVocabularySQL, tenantID, unseenSince.UTC(), maxPerField)
+	if err != nil {
+		return 0, fmt.Errorf("postgres: evict vocabulary: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SearchVocabulary returns the values of field in the tenant vocabulary
+// matching query, case-insensitively. With prefixOnly only values starting
+// with query match; otherwise values containing it match too, after those
+// starting with it. Within each group the most frequent come first.
+func (p *PostgresClient) SearchVocabulary(ctx context.Context, tenantID uuid.UUID, field, query string, prefixOnly bool, limit int) ([]domain.VocabularyTerm, error) {
+	prefix := escapeLikePattern(strings.ToLower(query)) + "%"
+	pattern := prefix
+	if !prefixOnly {
+		pattern = "%" + prefix
+	}
+
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT field, value, occurrences, job_count, last_job_id, first_seen_at, last_seen_at
+		FROM tenant_vocabulary
+		WHERE tenant_id = $1 AND field = $2 AND lower(value) LIKE $3
+		ORDER BY lower(value) LIKE $4 DESC, occurrences DESC, value
+		LIMIT $5
+	`, tenantID, field, pattern, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: search vocabulary: %w", err)
+	}
+	defer rows.Close()
+
+	terms := []domain.VocabularyTerm{}
+	for rows.Next() {
+		var t domain.VocabularyTerm
+		if err := rows.Scan(&t.Field, &t.Value, &t.Occurrences, &t.JobCount, &t.LastJobID, &t.FirstSeenAt, &t.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("postgres: scan vocabulary term: %w", err)
+		}
+		terms = append(terms, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: vocabulary rows: %w", err)
+	}
+	return terms, nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestVocabularyBatch(t *testing.T) {
+	terms, counts := vocabularyBatch([]domain.AutocompleteValue{
+		{Value: "HPD:Help Desk", Count: 10},
+		{Value: "  HPD:Help Desk ", Count: 5},
+		{Value: "CHG:Infrastructure Change", Count: 3},
+		{Value: "   ", Count: 7},
+		{Value: strings.Repeat("x", maxVocabularyValueLen+1), Count: 1},
+	})
+
+	assert.Equal(t, []string{"CHG:Infrastructure Change", "HPD:Help Desk"}, terms, "trimmed, merged and sorted")
+	assert.Equal(t, []int64{3, 15}, counts)
+
+	terms, counts = vocabularyBatch(nil)
+	assert.Empty(t, terms)
+	assert.Empty(t, counts)
+}
+
+func TestVocabularyBatch_ManyValuesStayOneBatch(t *testing.T) {
+	values := make([]domain.AutocompleteValue, 5000)
+	for i := range values {
+		values[i] = domain.AutocompleteValue{Value: fmt.Sprintf("Filter %04d", i), Count: int64(i + 1)}
+	}
+
+	terms, counts := vocabularyBatch(values)
+	assert.Len(t, terms, 5000)
+	assert.Len(t, counts, 5000)
+	assert.Equal(t, "Filter 0000", terms[0])
+	assert.Equal(t, int64(5000), counts[4999])
+}
+
+func TestUpsertVocabularySQL_IsOneStatement(t *testing.T) {
+	// Every value of a field is bound as an array and unnested, so a job
+	// with thousands of filters costs one round trip per field.
+	sql := strings.TrimSpace(upsertVocabularySQL)
+	assert.True(t, strings.HasPrefix(sql, "INSERT INTO tenant_vocabulary"))
+	assert.Equal(t, 1, strings.Count(sql, "INSERT"))
+	assert.NotContains(t, sql, ";")
+	assert.Contains(t, sql, "unnest($4::text[], $5::bigint[])")
+	assert.Contains(t, sql, "ON CONFLICT (tenant_id, field, value) DO UPDATE")
+	assert.Contains(t, sql, "tenant_vocabulary.last_job_id = EXCLUDED.last_job_id THEN 0", "retried jobs do not count twice")
+}
+
+func TestEvictVocabularySQL(t *testing.T) {
+	sql := strings.TrimSpace(evictVocabularySQL)
+	assert.True(t, strings.HasPrefix(sql, "DELETE FROM tenant_vocabulary"))
+	assert.Contains(t, sql, "PARTITION BY field ORDER BY last_seen_at DESC", "the least recently seen values go first")
+	assert.Contains(t, sql, "v.last_seen_at < $2 OR r.recency > $3")
+}
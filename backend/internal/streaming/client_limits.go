@@ -0,0 +1,108 @@
+package streaming
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// clientMessageRate is the sustained number of messages per second a
+	// client may send; clientMessageBurst is how many it may send at once.
+	clientMessageRate  = 10
+	clientMessageBurst = 20
+
+	// A client that has more than maxDroppedMessages messages rejected by
+	// the rate limit within rateAbuseWindow is disconnected.
+	maxDroppedMessages = 50
+	rateAbuseWindow    = 10 * time.Second
+
+	// A client changing its subscriptions more than churnWarnThreshold times
+	// within churnWindow is logged.
+	churnWarnThreshold = 60
+	churnWindow        = time.Minute
+)
+
+// messageLimiter is the token bucket limiting the messages of one client.
+// It is only used from the client's read pump.
+type messageLimiter struct {
+	tokens float64
+	last   time.Time
+
+	dropped     int
+	windowStart time.Time
+}
+
+func newMessageLimiter(now time.Time) *messageLimiter {
+	return &messageLimiter{tokens: clientMessageBurst, last: now, windowStart: now}
+}
+
+// allow takes a token for a message received at now. ok is false when the
+// message must be dropped; abusive is true once the client has had too many
+// messages dropped in the current window and should be disconnected.
+func (l *messageLimiter) allow(now time.Time) (ok, abusive bool) {
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 {
+		l.tokens = min(clientMessageBurst, l.tokens+elapsed*clientMessageRate)
+		l.last = now
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, false
+	}
+
+	if now.Sub(l.windowStart) > rateAbuseWindow {
+		l.windowStart, l.dropped = now, 0
+	}
+	l.dropped++
+	return false, l.dropped > maxDroppedMessages
+}
+
+// churnCounter counts the subscription changes of one client per window.
+type churnCounter struct {
+	count       int
+	windowStart time.Time
+}
+
+// add records a subscription change at now and reports whether the count
+// just crossed churnWarnThreshold, so that a churning client is logged once
+// per window.
+func (cc *churnCounter) add(now time.Time) (int, bool) {
+	if now.Sub(cc.windowStart) > churnWindow {
+		cc.windowStart, cc.count = now, 0
+	}
+	cc.count++
+	return cc.count, cc.count == churnWarnThreshold+1
+}
+
+// liveTailLogTypes are the log types a client may tail, keyed by their
+// lower-case wire form.
+var liveTailLogTypes = map[string]domain.LogType{
+	"api":  domain.LogTypeAPI,
+	"sql":  domain.LogTypeSQL,
+	"fltr": domain.LogTypeFilter,
+	"escl": domain.LogTypeEscalation,
+}
+
+// normalizeJobID validates a job ID from a client payload and returns it in
+// canonical form, so that equivalent spellings map to the same topic.
+func normalizeJobID(jobID string) (string, error) {
+	id, err := uuid.Parse(jobID)
+	if err != nil {
+		return "", fmt.Errorf("job_id must be a UUID")
+	}
+	return id.String(), nil
+}
+
+// normalizeLogType validates a log type from a client payload and returns
+// its lower-case form.
+func normalizeLogType(logType string) (string, error) {
+	lt := strings.ToLower(logType)
+	if _, ok := liveTailLogTypes[lt]; !ok {
+		return "", fmt.Errorf("unknown log_type %q", logType)
+	}
+	return lt, nil
+}
@@ -0,0 +1,199 @@
+package streaming
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageLimiter(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newMessageLimiter(start)
+
+	for i := 0; i < clientMessageBurst; i++ {
+		ok, abusive := l.allow(start)
+		require.True(t, ok, "message %d within burst", i)
+		require.False(t, abusive)
+	}
+	ok, abusive := l.allow(start)
+	assert.False(t, ok, "burst exhausted")
+	assert.False(t, abusive)
+
+	// Tokens refill at clientMessageRate per second.
+	ok, _ = l.allow(start.Add(time.Second / clientMessageRate))
+	assert.True(t, ok)
+}
+
+func TestMessageLimiter_SustainedAbuse(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newMessageLimiter(start)
+	for i := 0; i < clientMessageBurst; i++ {
+		l.allow(start)
+	}
+
+	for i := 1; i <= maxDroppedMessages; i++ {
+		ok, abusive := l.allow(start)
+		require.False(t, ok)
+		require.False(t, abusive, "drop %d is tolerated", i)
+	}
+	_, abusive := l.allow(start)
+	assert.True(t, abusive)
+}
+
+func TestMessageLimiter_DropsForgivenAfterWindow(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newMessageLimiter(start)
+	for i := 0; i < clientMessageBurst+maxDroppedMessages; i++ {
+		l.allow(start)
+	}
+
+	// Next window: the budget has refilled and earlier drops are forgotten.
+	later := start.Add(rateAbuseWindow + time.Second)
+	for i := 0; i < clientMessageBurst; i++ {
+		l.allow(later)
+	}
+	ok, abusive := l.allow(later)
+	assert.False(t, ok)
+	assert.False(t, abusive)
+}
+
+func TestChurnCounter(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var cc churnCounter
+	warnings := 0
+	for i := 0; i < 3*churnWarnThreshold; i++ {
+		if _, warn := cc.add(start); warn {
+			warnings++
+		}
+	}
+	assert.Equal(t, 1, warnings, "warns once per window")
+
+	n, _ := cc.add(start.Add(churnWindow + time.Second))
+	assert.Equal(t, 1, n, "count resets in a new window")
+}
+
+func TestNormalizeJobID(t *testing.T) {
+	id, err := normalizeJobID("{AAAAAAAA-1111-2222-3333-444444444444}")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaaa-1111-2222-3333-444444444444", id)
+
+	for _, bad := range []string{"job-1", "tenant-b.job", "*", ">", "aaaaaaaa-1111-2222-3333-44444444444.", strings.Repeat("a", 1000)} {
+		_, err := normalizeJobID(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNormalizeLogType(t *testing.T) {
+	lt, err := normalizeLogType("SQL")
+	require.NoError(t, err)
+	assert.Equal(t, "sql", lt)
+
+	for _, bad := range []string{"exception", "sql.tenant-b", "*", ""} {
+		_, err := normalizeLogType(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTopicToken(t *testing.T) {
+	assert.Equal(t, "tenant-1", topicToken("tenant-1"))
+	assert.Equal(t, "a%2Eb%25c", topicToken("a.b%c"))
+	assert.Equal(t,
+		"job_progress.tenant-a.tenant-b%2Ejob",
+		jobProgressTopic("tenant-a", "tenant-b.job"))
+}
+
+// FuzzTopicBuilders checks that no job ID or log type of one tenant yields a
+// topic with a different segment count or that collides with the topic of
+// another tenant.
+func FuzzTopicBuilders(f *testing.F) {
+	f.Add("tenant-a", "11111111-1111-1111-1111-111111111111", "tenant-b")
+	f.Add("tenant-a", "x.tenant-b.y", "tenant-a.x")
+	f.Add("t%2Ea", "%", "t.a")
+	f.Add("", ".", ".")
+
+	f.Fuzz(func(t *testing.T, tenantA, value, tenantB string) {
+		builders := []func(string, string) string{jobProgressTopic, jobCompleteTopic, liveTailTopic}
+		for _, build := range builders {
+			topic := build(tenantA, value)
+			if got := strings.Count(topic, "."); got != 2 {
+				t.Fatalf("topic %q has %d delimiters, want 2", topic, got)
+			}
+			if tenantA != tenantB && topic == build(tenantB, "11111111-1111-1111-1111-111111111111") {
+				t.Fatalf("tenant %q value %q collides with tenant %q", tenantA, value, tenantB)
+			}
+			// The tenant segment is always the escaped tenant.
+			if seg := strings.Split(topic, ".")[1]; seg != topicToken(tenantA) {
+				t.Fatalf("tenant segment %q, want %q", seg, topicToken(tenantA))
+			}
+		}
+		if strings.Count(investigationsTopic(value), ".") != 1 {
+			t.Fatalf("investigations topic for %q spans segments", value)
+		}
+	})
+}
+
+func TestClientCannotSubscribeToOtherTenantTopic(t *testing.T) {
+	hub := startTestHub(t)
+	client := newTestClient(hub, "tenant-a")
+	hub.register <- client
+	settle(t, hub)
+
+	victimJob := "11111111-1111-1111-1111-111111111111"
+	crafted := []ClientMessage{
+		{Type: MsgTypeSubscribeJobProgress, Payload: json.RawMessage(`{"job_id":"x.tenant-b.` + victimJob + `"}`)},
+		{Type: MsgTypeSubscribeJobProgress, Payload: json.RawMessage(`{"job_id":"*"}`)},
+		{Type: MsgTypeSubscribeJobProgress, Payload: json.RawMessage(`{"job_id":">"}`)},
+		{Type: MsgTypeSubscribeLiveTail, Payload: json.RawMessage(`{"log_type":"sql.tenant-b"}`)},
+		{Type: MsgTypeSubscribeLiveTail, Payload: json.RawMessage(`{"log_type":"../tenant-b/sql"}`)},
+	}
+	for _, msg := range crafted {
+		raw, _ := json.Marshal(msg)
+		client.handleMessage(raw)
+
+		require.Equal(t, 1, len(client.send), "payload %s", msg.Payload)
+		var resp ServerMessage
+		require.NoError(t, json.Unmarshal(<-client.send, &resp))
+		assert.Equal(t, MsgTypeError, resp.Type)
+	}
+
+	client.subsMu.Lock()
+	assert.Empty(t, client.subscriptions)
+	client.subsMu.Unlock()
+
+	// Broadcasts to tenant B's topics never reach the client.
+	hub.Broadcast(jobProgressTopic("tenant-b", victimJob), ServerMessage{Type: MsgTypeJobProgress})
+	hub.Broadcast(liveTailTopic("tenant-b", "sql"), ServerMessage{Type: MsgTypeLiveTailEntry})
+	settle(t, hub)
+	assert.Equal(t, 0, len(client.send))
+}
+
+func TestWebSocketRateLimitClosesConnection(t *testing.T) {
+	hub := startTestHub(t)
+	_, wsURL := wsTestServer(t, hub)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ping, _ := json.Marshal(ClientMessage{Type: MsgTypePing})
+	for i := 0; i < clientMessageBurst+maxDroppedMessages+1; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, ping))
+	}
+
+	// Pongs and RATE_LIMITED errors may precede the close frame.
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var closeErr *websocket.CloseError
+			require.True(t, errors.As(err, &closeErr), "expected close frame, got %v", err)
+			assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+			return
+		}
+	}
+}
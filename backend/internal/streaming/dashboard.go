@@ -0,0 +1,243 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDashboardParallelism is how many sections of a dashboard are
+	// loaded at once.
+	DefaultDashboardParallelism = 3
+
+	// Time allowed to stream a whole dashboard.
+	dashboardStreamTimeout = 2 * time.Minute
+)
+
+// Sources of a dashboard section.
+const (
+	DashboardSourceCache = "cache"
+	DashboardSourceFresh = "fresh"
+)
+
+// DashboardSource gives the sections of a completed analysis's dashboard,
+// streamed on subscribe_dashboard. The API implements it with the loads of
+// its REST section handlers.
+type DashboardSource interface {
+	// DashboardSections returns the sections of the job's dashboard in the
+	// order they are started. It fails when the job is not a complete
+	// analysis of the tenant.
+	DashboardSections(ctx context.Context, tenantID, jobID string) ([]DashboardSection, error)
+}
+
+// DashboardSection is one section of a dashboard.
+type DashboardSection struct {
+	Name string
+	// Load returns the section and whether it was read from the cache.
+	Load func(ctx context.Context) (payload any, cached bool, err error)
+}
+
+// SubscribeDashboardPayload is sent by the client to stream the dashboard
+// of a completed analysis.
+type SubscribeDashboardPayload struct {
+	JobID string `json:"job_id"`
+}
+
+// DashboardSectionPayload carries one section of a streamed dashboard.
+type DashboardSectionPayload struct {
+	JobID   string `json:"job_id"`
+	Section string `json:"section"`
+	Source  string `json:"source"`
+	Payload any    `json:"payload"`
+}
+
+// DashboardCompletePayload ends a streamed dashboard: the sections sent and
+// those that failed.
+type DashboardCompletePayload struct {
+	JobID    string                  `json:"job_id"`
+	Sections []string                `json:"sections"`
+	Failed   []DashboardSectionError `json:"failed"`
+}
+
+// DashboardSectionError is a section that could not be loaded.
+type DashboardSectionError struct {
+	Section string `json:"section"`
+	Error   string `json:"error"`
+}
+
+// SetDashboardSource enables subscribe_dashboard, loading at most
+// parallelism sections at once (DefaultDashboardParallelism when <= 0).
+// It must be called before clients connect.
+func (h *Hub) SetDashboardSource(src DashboardSource, parallelism int) {
+	if parallelism <= 0 {
+		parallelism = DefaultDashboardParallelism
+	}
+	h.dashboards = src
+	h.dashboardParallelism = parallelism
+}
+
+// dashboardStream is the dashboard a client is streaming. A client streams
+// one dashboard at a time: subscribing again cancels the stream under way,
+// whose sections not yet sent are dropped rather than queued behind the
+// new ones.
+type dashboardStream struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+
+	// sendMu guards the send channel against being closed while a stream
+	// writes to it; closed is set once it is.
+	sendMu sync.RWMutex
+	closed bool
+}
+
+func (c *Client) handleSubscribeDashboard(payload json.RawMessage) {
+	var p SubscribeDashboardPayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.JobID == "" {
+		c.sendError("INVALID_PAYLOAD", "job_id is required for subscribe_dashboard")
+		return
+	}
+	jobID, err := normalizeJobID(p.JobID)
+	if err != nil {
+		c.sendError("INVALID_PAYLOAD", err.Error())
+		return
+	}
+	if c.hub.dashboards == nil {
+		c.sendError("SUBSCRIBE_FAILED", "dashboard streaming is not available")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dashboardStreamTimeout)
+	c.dashboard.mu.Lock()
+	if c.dashboard.cancel != nil {
+		c.dashboard.cancel()
+	}
+	c.dashboard.cancel = cancel
+	c.dashboard.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		c.streamDashboard(ctx, jobID)
+	}()
+}
+
+// stopDashboard cancels the dashboard stream under way, if any.
+func (c *Client) stopDashboard() {
+	c.dashboard.mu.Lock()
+	defer c.dashboard.mu.Unlock()
+	if c.dashboard.cancel != nil {
+		c.dashboard.cancel()
+		c.dashboard.cancel = nil
+	}
+}
+
+// closeSend stops the client's dashboard stream and closes its send
+// channel once no stream is writing to it.
+func (c *Client) closeSend() {
+	c.stopDashboard()
+	c.dashboard.sendMu.Lock()
+	c.dashboard.closed = true
+	c.dashboard.sendMu.Unlock()
+	close(c.send)
+}
+
+// streamDashboard loads the sections of the job's dashboard, starting them
+// in the source's order with at most the hub's parallelism, and sends each
+// as it is ready. A failed section is reported in dashboard_complete and
+// does not hold up the others.
+func (c *Client) streamDashboard(ctx context.Context, jobID string) {
+	sections, err := c.hub.dashboards.DashboardSections(ctx, c.tenantID, jobID)
+	if err != nil {
+		_ = c.sendStream(ctx, ServerMessage{Type: MsgTypeError, Payload: ErrorPayload{Code: "SUBSCRIBE_FAILED", Message: err.Error()}})
+		return
+	}
+
+	type loaded struct {
+		name    string
+		payload any
+		cached  bool
+		err     error
+	}
+	results := make(chan loaded, len(sections))
+	go func() {
+		sem := make(chan struct{}, c.hub.dashboardParallelism)
+		for _, s := range sections {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				defer func() { <-sem }()
+				r := loaded{name: s.Name}
+				defer func() {
+					if p := recover(); p != nil {
+						r.err = fmt.Errorf("panic: %v", p)
+					}
+					results <- r
+				}()
+				r.payload, r.cached, r.err = s.Load(ctx)
+			}()
+		}
+	}()
+
+	complete := DashboardCompletePayload{JobID: jobID, Sections: []string{}, Failed: []DashboardSectionError{}}
+	for range sections {
+		var r loaded
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return
+		}
+		if r.err != nil {
+			c.logger.Warn("dashboard section failed", "job_id", jobID, "section", r.name, "error", r.err)
+			complete.Failed = append(complete.Failed, DashboardSectionError{Section: r.name, Error: r.err.Error()})
+			continue
+		}
+		source := DashboardSourceFresh
+		if r.cached {
+			source = DashboardSourceCache
+		}
+		err := c.sendStream(ctx, ServerMessage{Type: MsgTypeDashboardSection, Payload: DashboardSectionPayload{
+			JobID: jobID, Section: r.name, Source: source, Payload: r.payload,
+		}})
+		if errors.Is(err, errStreamStopped) {
+			return
+		}
+		if err != nil {
+			complete.Failed = append(complete.Failed, DashboardSectionError{Section: r.name, Error: err.Error()})
+			continue
+		}
+		complete.Sections = append(complete.Sections, r.name)
+	}
+	_ = c.sendStream(ctx, ServerMessage{Type: MsgTypeDashboardComplete, Payload: complete})
+}
+
+// errStreamStopped is returned by sendStream once the stream was cancelled
+// or the client is gone.
+var errStreamStopped = errors.New("dashboard stream stopped")
+
+// sendStream queues a message of a dashboard stream, waiting for room in
+// the send buffer rather than dropping it.
+func (c *Client) sendStream(ctx context.Context, msg ServerMessage) error {
+	data, err := json.Marshal(c.envelope(msg))
+	if err != nil {
+		c.logger.Error("marshal server message", "error", err, "type", msg.Type)
+		return fmt.Errorf("encode %s: %w", msg.Type, err)
+	}
+
+	c.dashboard.sendMu.RLock()
+	defer c.dashboard.sendMu.RUnlock()
+	if c.dashboard.closed || ctx.Err() != nil {
+		return errStreamStopped
+	}
+	select {
+	case c.send <- data:
+		return nil
+	case <-ctx.Done():
+		return errStreamStopped
+	}
+}
@@ -0,0 +1,279 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDashboards serves sections built by sections for every job.
+type fakeDashboards struct {
+	sections func(jobID string) []DashboardSection
+	err      error
+}
+
+func (f *fakeDashboards) DashboardSections(ctx context.Context, tenantID, jobID string) ([]DashboardSection, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.sections(jobID), nil
+}
+
+// readySection is a section loaded after delay.
+func readySection(name string, delay time.Duration, cached bool) DashboardSection {
+	return DashboardSection{Name: name, Load: func(ctx context.Context) (any, bool, error) {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		return map[string]string{"name": name}, cached, nil
+	}}
+}
+
+// dashboardMessage is a server message with its payload left raw.
+type dashboardMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// dialDashboard connects to a test server of a hub streaming dashboards
+// from src.
+func dialDashboard(t *testing.T, src DashboardSource, parallelism int) *websocket.Conn {
+	t.Helper()
+	hub := startTestHub(t)
+	hub.SetDashboardSource(src, parallelism)
+	_, wsURL := wsTestServer(t, hub)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func subscribeDashboard(t *testing.T, conn *websocket.Conn, jobID string) {
+	t.Helper()
+	payload, _ := json.Marshal(SubscribeDashboardPayload{JobID: jobID})
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: MsgTypeSubscribeDashboard, Payload: payload}))
+}
+
+// readDashboard reads messages up to and including dashboard_complete,
+// returning the sections in the order they arrived.
+func readDashboard(t *testing.T, conn *websocket.Conn) ([]DashboardSectionPayload, DashboardCompletePayload) {
+	t.Helper()
+	var sections []DashboardSectionPayload
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg dashboardMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		switch msg.Type {
+		case MsgTypeDashboardSection:
+			var p DashboardSectionPayload
+			require.NoError(t, json.Unmarshal(msg.Payload, &p))
+			sections = append(sections, p)
+		case MsgTypeDashboardComplete:
+			var p DashboardCompletePayload
+			require.NoError(t, json.Unmarshal(msg.Payload, &p))
+			return sections, p
+		default:
+			t.Fatalf("unexpected message %s: %s", msg.Type, msg.Payload)
+		}
+	}
+}
+
+func sectionNames(sections []DashboardSectionPayload) []string {
+	names := make([]string, len(sections))
+	for i, s := range sections {
+		names[i] = s.Section
+	}
+	return names
+}
+
+const dashboardJobID = "44444444-4444-4444-4444-444444444444"
+
+func TestDashboardStream_PriorityOrder(t *testing.T) {
+	src := &fakeDashboards{sections: func(string) []DashboardSection {
+		return []DashboardSection{
+			readySection("dashboard", 30*time.Millisecond, true),
+			readySection("aggregates", 0, false),
+			readySection("exceptions", 10*time.Millisecond, true),
+			readySection("threads", 0, false),
+		}
+	}}
+	conn := dialDashboard(t, src, 1)
+
+	subscribeDashboard(t, conn, dashboardJobID)
+	sections, complete := readDashboard(t, conn)
+
+	assert.Equal(t, []string{"dashboard", "aggregates", "exceptions", "threads"}, sectionNames(sections),
+		"one at a time, sections arrive in priority order whatever their load time")
+	assert.Equal(t, DashboardSourceCache, sections[0].Source)
+	assert.Equal(t, DashboardSourceFresh, sections[1].Source)
+	assert.Equal(t, dashboardJobID, sections[0].JobID)
+	assert.JSONEq(t, `{"name":"dashboard"}`, mustJSON(t, sections[0].Payload))
+
+	assert.Equal(t, dashboardJobID, complete.JobID)
+	assert.Equal(t, []string{"dashboard", "aggregates", "exceptions", "threads"}, complete.Sections)
+	assert.Empty(t, complete.Failed)
+}
+
+func TestDashboardStream_SlowSectionDoesNotHoldUpOthers(t *testing.T) {
+	src := &fakeDashboards{sections: func(string) []DashboardSection {
+		return []DashboardSection{
+			readySection("dashboard", 0, true),
+			readySection("aggregates", 300*time.Millisecond, false),
+			readySection("exceptions", 0, false),
+			readySection("threads", 0, false),
+		}
+	}}
+	conn := dialDashboard(t, src, 2)
+
+	subscribeDashboard(t, conn, dashboardJobID)
+	sections, complete := readDashboard(t, conn)
+
+	names := sectionNames(sections)
+	require.Len(t, names, 4)
+	assert.Equal(t, "dashboard", names[0])
+	assert.Equal(t, "aggregates", names[3], "the slow section arrives last")
+	assert.ElementsMatch(t, []string{"dashboard", "aggregates", "exceptions", "threads"}, complete.Sections)
+}
+
+func TestDashboardStream_FailedSectionsAreIsolated(t *testing.T) {
+	src := &fakeDashboards{sections: func(string) []DashboardSection {
+		return []DashboardSection{
+			readySection("dashboard", 0, true),
+			{Name: "aggregates", Load: func(context.Context) (any, bool, error) {
+				return nil, false, errors.New("aggregates data not available")
+			}},
+			{Name: "exceptions", Load: func(context.Context) (any, bool, error) {
+				panic("boom")
+			}},
+			readySection("threads", 0, false),
+		}
+	}}
+	conn := dialDashboard(t, src, 1)
+
+	subscribeDashboard(t, conn, dashboardJobID)
+	sections, complete := readDashboard(t, conn)
+
+	assert.Equal(t, []string{"dashboard", "threads"}, sectionNames(sections))
+	assert.Equal(t, []string{"dashboard", "threads"}, complete.Sections)
+	assert.Equal(t, []DashboardSectionError{
+		{Section: "aggregates", Error: "aggregates data not available"},
+		{Section: "exceptions", Error: "panic: boom"},
+	}, complete.Failed)
+}
+
+func TestDashboardStream_ResubscribeDropsStaleSections(t *testing.T) {
+	const otherJobID = "55555555-5555-5555-5555-555555555555"
+	loading := make(chan struct{})
+	src := &fakeDashboards{sections: func(jobID string) []DashboardSection {
+		if jobID == dashboardJobID {
+			stale := readySection("dashboard", time.Hour, true)
+			load := stale.Load
+			stale.Load = func(ctx context.Context) (any, bool, error) {
+				close(loading)
+				return load(ctx)
+			}
+			return []DashboardSection{stale}
+		}
+		return []DashboardSection{readySection("dashboard", 0, true), readySection("gaps", 0, false)}
+	}}
+	conn := dialDashboard(t, src, 1)
+
+	subscribeDashboard(t, conn, dashboardJobID)
+	select {
+	case <-loading:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first dashboard never started loading")
+	}
+	subscribeDashboard(t, conn, otherJobID)
+	sections, complete := readDashboard(t, conn)
+
+	for _, s := range sections {
+		assert.Equal(t, otherJobID, s.JobID)
+	}
+	assert.Equal(t, []string{"dashboard", "gaps"}, sectionNames(sections))
+	assert.Equal(t, otherJobID, complete.JobID, "the cancelled stream sends no dashboard_complete")
+}
+
+func TestDashboardStream_Errors(t *testing.T) {
+	t.Run("unknown job", func(t *testing.T) {
+		conn := dialDashboard(t, &fakeDashboards{err: errors.New("analysis job not found")}, 1)
+		subscribeDashboard(t, conn, dashboardJobID)
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg ServerMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, MsgTypeError, msg.Type)
+		assert.Contains(t, mustJSON(t, msg.Payload), "analysis job not found")
+	})
+
+	t.Run("missing job ID", func(t *testing.T) {
+		conn := dialDashboard(t, &fakeDashboards{}, 1)
+		require.NoError(t, conn.WriteJSON(ClientMessage{Type: MsgTypeSubscribeDashboard, Payload: json.RawMessage(`{}`)}))
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg ServerMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, MsgTypeError, msg.Type)
+		assert.Contains(t, mustJSON(t, msg.Payload), "INVALID_PAYLOAD")
+	})
+
+	t.Run("streaming disabled", func(t *testing.T) {
+		hub := startTestHub(t)
+		_, wsURL := wsTestServer(t, hub)
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		subscribeDashboard(t, conn, dashboardJobID)
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg ServerMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, MsgTypeError, msg.Type)
+		assert.Contains(t, mustJSON(t, msg.Payload), "not available")
+	})
+}
+
+func TestDashboardStream_ClientGoneStopsStream(t *testing.T) {
+	hub := startTestHub(t)
+	started := make(chan struct{})
+	hub.SetDashboardSource(&fakeDashboards{sections: func(string) []DashboardSection {
+		return []DashboardSection{{Name: "dashboard", Load: func(ctx context.Context) (any, bool, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, false, ctx.Err()
+		}}}
+	}}, 1)
+	client := newTestClient(hub, "t1")
+	hub.register <- client
+
+	payload, _ := json.Marshal(SubscribeDashboardPayload{JobID: dashboardJobID})
+	client.handleMessage(mustMarshal(t, ClientMessage{Type: MsgTypeSubscribeDashboard, Payload: payload}))
+	<-started
+	hub.unregister <- client
+
+	// The send channel is closed without a panic and the stream ends.
+	require.Eventually(t, func() bool {
+		_, open := <-client.send
+		return !open
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	return string(mustMarshal(t, v))
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
@@ -14,7 +14,12 @@ type NATSStreamer interface {
 	SubscribeLiveTail(ctx context.Context, tenantID string, logType string, handler func(domain.LogEntry)) error
 	PublishJobSubmit(ctx context.Context, tenantID string, job domain.AnalysisJob) error
 	PublishJobProgress(ctx context.Context, tenantID string, jobID string, progress int, status string, message string) error
+	PublishJobQueued(ctx context.Context, tenantID string, jobID string, queue domain.JobQueueEstimate) error
 	PublishJobComplete(ctx context.Context, tenantID string, jobID string, result domain.AnalysisJob) error
+	PublishExportSubmit(ctx context.Context, tenantID string, export domain.SearchExport) error
+	SubscribeAllExportSubmits(ctx context.Context, handler func(domain.SearchExport)) error
+	PublishThresholdViolation(ctx context.Context, tenantID string, violation domain.ThresholdViolation) error
+	PublishCaptureDrift(ctx context.Context, tenantID string, event domain.CaptureDriftEvent) error
 	PublishLiveTailEntry(ctx context.Context, tenantID string, logType string, entry domain.LogEntry) error
 	Ping() error
 	Close()
@@ -3,8 +3,11 @@ package streaming
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
@@ -20,6 +23,9 @@ type JobProgress struct {
 	ProcessedLines int64  `json:"processed_lines"`
 	TotalLines     int64  `json:"total_lines"`
 	Message        string `json:"message"`
+
+	// Queue is set on the updates of queued jobs.
+	Queue *domain.JobQueueEstimate `json:"queue,omitempty"`
 }
 
 // NATSClient wraps a NATS connection with JetStream support for
@@ -30,10 +36,60 @@ type NATSClient struct {
 	logger *slog.Logger
 }
 
+// NATSAuth selects how a NATSClient authenticates. At most one method may
+// be set; the zero value connects without credentials. Deployments that
+// isolate tenants give the API and worker their own scoped credentials.
+type NATSAuth struct {
+	CredsFile    string // JWT + NKEY user credentials file
+	NKeySeedFile string // NKEY seed file
+	User         string
+	Password     string
+}
+
+// options validates the credentials and returns the matching connect
+// options. Credential files are read here so that a misconfigured process
+// fails at startup rather than on its first reconnect.
+func (a NATSAuth) options() ([]nats.Option, error) {
+	methods := 0
+	for _, v := range []string{a.CredsFile, a.NKeySeedFile, a.User} {
+		if v != "" {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return nil, fmt.Errorf("nats auth: only one of creds file, nkey seed or user may be set")
+	}
+
+	switch {
+	case a.CredsFile != "":
+		if _, err := os.ReadFile(a.CredsFile); err != nil {
+			return nil, fmt.Errorf("nats auth: creds file: %w", err)
+		}
+		return []nats.Option{nats.UserCredentials(a.CredsFile)}, nil
+	case a.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(a.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("nats auth: nkey seed: %w", err)
+		}
+		return []nats.Option{opt}, nil
+	case a.User != "":
+		if a.Password == "" {
+			return nil, fmt.Errorf("nats auth: password is required with user")
+		}
+		return []nats.Option{nats.UserInfo(a.User, a.Password)}, nil
+	}
+	return nil, nil
+}
+
 // NewNATSClient connects to a NATS server and enables JetStream.
-func NewNATSClient(url string) (*NATSClient, error) {
+func NewNATSClient(url string, auth NATSAuth) (*NATSClient, error) {
 	logger := slog.Default().With("component", "nats")
 
+	authOpts, err := auth.options()
+	if err != nil {
+		return nil, err
+	}
+
 	opts := []nats.Option{
 		nats.Name("remedyiq"),
 		nats.MaxReconnects(-1),
@@ -47,6 +103,7 @@ func NewNATSClient(url string) (*NATSClient, error) {
 			logger.Info("NATS reconnected", "url", nc.ConnectedUrl())
 		}),
 	}
+	opts = append(opts, authOpts...)
 
 	nc, err := nats.Connect(url, opts...)
 	if err != nil {
@@ -74,15 +131,18 @@ func (c *NATSClient) Close() {
 }
 
 // EnsureStreams creates the required JetStream streams if they do not already
-// exist. Two streams are provisioned:
+// exist. Both streams capture tenant-scoped subjects only (see subjects.go),
+// so per-tenant credentials can be granted "<root>.<tenant_id>.>". Two
+// streams are provisioned:
 //
-//	JOBS  -- captures job lifecycle events (submit, progress, complete)
-//	EVENTS -- captures live tail log entries
+//	JOBS  -- captures job lifecycle events (submit, progress, complete) and
+//	         search export requests
+//	EVENTS -- captures live tail log entries and threshold alerts
 func (c *NATSClient) EnsureStreams(ctx context.Context) error {
 	jobsCfg := jetstream.StreamConfig{
 		Name:        "JOBS",
 		Description: "Job lifecycle events (submit, progress, complete)",
-		Subjects:    []string{"jobs.>"},
+		Subjects:    jobsStreamSubjects(),
 		Retention:   jetstream.WorkQueuePolicy,
 		MaxAge:      24 * time.Hour,
 		Storage:     jetstream.FileStorage,
@@ -94,7 +154,7 @@ func (c *NATSClient) EnsureStreams(ctx context.Context) error {
 	eventsCfg := jetstream.StreamConfig{
 		Name:        "EVENTS",
 		Description: "Live tail log entries and other real-time events",
-		Subjects:    []string{"logs.>", "ai.>"},
+		Subjects:    eventsStreamSubjects(),
 		Retention:   jetstream.InterestPolicy,
 		MaxAge:      1 * time.Hour,
 		Storage:     jetstream.FileStorage,
@@ -114,26 +174,6 @@ func (c *NATSClient) EnsureStreams(ctx context.Context) error {
 	return nil
 }
 
-// ---------------------------------------------------------------------------
-// Subject helpers
-// ---------------------------------------------------------------------------
-
-func subjectJobSubmit(tenantID string) string {
-	return fmt.Sprintf("jobs.%s.submit", tenantID)
-}
-
-func subjectJobProgress(tenantID string) string {
-	return fmt.Sprintf("jobs.%s.progress", tenantID)
-}
-
-func subjectJobComplete(tenantID string) string {
-	return fmt.Sprintf("jobs.%s.complete", tenantID)
-}
-
-func subjectLiveTail(tenantID, logType string) string {
-	return fmt.Sprintf("logs.%s.tail.%s", tenantID, logType)
-}
-
 // ---------------------------------------------------------------------------
 // Publish helpers
 // ---------------------------------------------------------------------------
@@ -157,9 +197,17 @@ func (c *NATSClient) publish(ctx context.Context, subject string, v any) error {
 // Job lifecycle publishers
 // ---------------------------------------------------------------------------
 
-// PublishJobSubmit publishes a new job submission event.
+// PublishJobSubmit publishes a new job submission event on the submit
+// subject of the job's priority.
 func (c *NATSClient) PublishJobSubmit(ctx context.Context, tenantID string, job domain.AnalysisJob) error {
-	return c.publish(ctx, subjectJobSubmit(tenantID), job)
+	if err := guardTenant(tenantID, job.TenantID.String()); err != nil {
+		return err
+	}
+	priority := job.Priority.OrDefault()
+	if !priority.Valid() {
+		return fmt.Errorf("publish job submit: unknown priority %q", priority)
+	}
+	return c.publish(ctx, subjectJobSubmitPriority(tenantID, priority), job)
 }
 
 // PublishJobProgress publishes a job progress update.
@@ -170,20 +218,74 @@ func (c *NATSClient) PublishJobProgress(ctx context.Context, tenantID string, jo
 		ProgressPct: progress,
 		Message:     message,
 	}
+	if err := guardTenant(tenantID, ""); err != nil {
+		return err
+	}
+	return c.publish(ctx, subjectJobProgress(tenantID), p)
+}
+
+// PublishJobQueued publishes a progress update of a queued job with its
+// place in the queue.
+func (c *NATSClient) PublishJobQueued(ctx context.Context, tenantID string, jobID string, queue domain.JobQueueEstimate) error {
+	p := JobProgress{
+		JobID:   jobID,
+		Status:  string(domain.JobStatusQueued),
+		Message: fmt.Sprintf("Queued at position %d", queue.Position),
+		Queue:   &queue,
+	}
+	if err := guardTenant(tenantID, ""); err != nil {
+		return err
+	}
 	return c.publish(ctx, subjectJobProgress(tenantID), p)
 }
 
 // PublishJobComplete publishes a job completion event.
 func (c *NATSClient) PublishJobComplete(ctx context.Context, tenantID string, jobID string, result domain.AnalysisJob) error {
+	if err := guardTenant(tenantID, result.TenantID.String()); err != nil {
+		return err
+	}
 	return c.publish(ctx, subjectJobComplete(tenantID), result)
 }
 
+// PublishExportSubmit queues a search export for the worker.
+func (c *NATSClient) PublishExportSubmit(ctx context.Context, tenantID string, export domain.SearchExport) error {
+	if err := guardTenant(tenantID, export.TenantID.String()); err != nil {
+		return err
+	}
+	return c.publish(ctx, subjectExportSubmit(tenantID), export)
+}
+
+// PublishThresholdViolation announces a critical threshold violation so
+// notifiers can alert on it.
+func (c *NATSClient) PublishThresholdViolation(ctx context.Context, tenantID string, violation domain.ThresholdViolation) error {
+	if err := guardTenant(tenantID, violation.TenantID.String()); err != nil {
+		return err
+	}
+	return c.publish(ctx, subjectThresholdAlert(tenantID), violation)
+}
+
+// PublishCaptureDrift announces an analysis whose capture was taken
+// differently from its reference capture, so notifiers can warn that it
+// does not compare with earlier ones.
+func (c *NATSClient) PublishCaptureDrift(ctx context.Context, tenantID string, event domain.CaptureDriftEvent) error {
+	if err := guardTenant(tenantID, event.TenantID.String()); err != nil {
+		return err
+	}
+	return c.publish(ctx, subjectCaptureDriftAlert(tenantID), event)
+}
+
 // ---------------------------------------------------------------------------
 // Live tail publishers
 // ---------------------------------------------------------------------------
 
 // PublishLiveTailEntry publishes a single log entry for real-time tailing.
 func (c *NATSClient) PublishLiveTailEntry(ctx context.Context, tenantID string, logType string, entry domain.LogEntry) error {
+	if err := guardTenant(tenantID, entry.TenantID); err != nil {
+		return err
+	}
+	if err := validateSubjectToken(logType); err != nil {
+		return err
+	}
 	return c.publish(ctx, subjectLiveTail(tenantID, logType), entry)
 }
 
@@ -192,38 +294,30 @@ func (c *NATSClient) PublishLiveTailEntry(ctx context.Context, tenantID string,
 // ---------------------------------------------------------------------------
 
 // SubscribeJobSubmit creates a durable consumer for job submission events
-// scoped to the given tenant. The handler is invoked for each message; the
-// message is acknowledged automatically after the handler returns without
-// panic. The returned ConsumeContext is stopped when ctx is cancelled.
+// of every priority scoped to the given tenant. The handler is invoked for
+// each message; the message is acknowledged automatically after the handler
+// returns without panic. The returned ConsumeContext is stopped when ctx is
+// cancelled.
 func (c *NATSClient) SubscribeJobSubmit(ctx context.Context, tenantID string, handler func(domain.AnalysisJob)) error {
-	subject := subjectJobSubmit(tenantID)
 	durableName := fmt.Sprintf("job-submit-%s", tenantID)
+	ackWait := 30 * time.Second
 
 	cons, err := c.js.CreateOrUpdateConsumer(ctx, "JOBS", jetstream.ConsumerConfig{
-		Durable:       durableName,
-		FilterSubject: subject,
+		Durable: durableName,
+		FilterSubjects: []string{
+			subjectJobSubmit(tenantID),
+			subjectJobSubmitPriority(tenantID, "*"),
+		},
 		AckPolicy:     jetstream.AckExplicitPolicy,
 		DeliverPolicy: jetstream.DeliverNewPolicy,
 		MaxDeliver:    5,
-		AckWait:       30 * time.Second,
+		AckWait:       ackWait,
 	})
 	if err != nil {
 		return fmt.Errorf("create consumer %s: %w", durableName, err)
 	}
 
-	cc, err := cons.Consume(func(msg jetstream.Msg) {
-		var job domain.AnalysisJob
-		if err := json.Unmarshal(msg.Data(), &job); err != nil {
-			c.logger.Error("unmarshal job submit", "error", err, "subject", subject)
-			// Terminal ack to avoid redelivery of malformed messages.
-			_ = msg.TermWithReason("unmarshal error")
-			return
-		}
-		handler(job)
-		if err := msg.Ack(); err != nil {
-			c.logger.Error("ack job submit", "error", err, "subject", subject)
-		}
-	})
+	cc, err := cons.Consume(c.jobSubmitHandler(ackWait, "", handler))
 	if err != nil {
 		return fmt.Errorf("consume %s: %w", durableName, err)
 	}
@@ -238,34 +332,165 @@ func (c *NATSClient) SubscribeJobSubmit(ctx context.Context, tenantID string, ha
 	return nil
 }
 
-// SubscribeAllJobSubmits creates a durable consumer for job submission events
-// across ALL tenants. This is used by the worker to pick up jobs from any tenant.
+// jobSubmitConsumers returns the subjects of the durable consumers of job
+// submissions by consumer name, and the priority of the jobs each carries.
+func jobSubmitConsumers() (subjects map[string]string, priorities map[string]domain.JobPriority) {
+	subjects = map[string]string{"worker-job-submit": subjectJobSubmit(subjectAllTenants)}
+	priorities = map[string]domain.JobPriority{"worker-job-submit": domain.JobPriorityNormal}
+	for _, p := range domain.JobPriorities {
+		durableName := "worker-job-submit-" + string(p)
+		subjects[durableName] = subjectJobSubmitPriority(subjectAllTenants, p)
+		priorities[durableName] = p
+	}
+	return subjects, priorities
+}
+
+// JobQueueDepth returns the backlog of the consumers of job submissions,
+// ordered by consumer name. Consumers no worker has created yet are left
+// out.
+func (c *NATSClient) JobQueueDepth(ctx context.Context) ([]domain.StreamDepth, error) {
+	subjects, _ := jobSubmitConsumers()
+	names := make([]string, 0, len(subjects))
+	for name := range subjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	depths := make([]domain.StreamDepth, 0, len(names))
+	for _, name := range names {
+		cons, err := c.js.Consumer(ctx, "JOBS", name)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("consumer %s: %w", name, err)
+		}
+		info, err := cons.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("consumer %s info: %w", name, err)
+		}
+		depths = append(depths, domain.StreamDepth{
+			Consumer:   name,
+			Subject:    subjects[name],
+			Pending:    info.NumPending,
+			AckPending: info.NumAckPending,
+		})
+	}
+	return depths, nil
+}
+
+// SubscribeAllJobSubmits creates durable consumers for job submission events
+// across ALL tenants, one per priority, so that the handler can pick among
+// the next job of each priority. This is used by the worker to pick up jobs
+// from any tenant. The handler is called concurrently for jobs of different
+// priorities and may block until the job is admitted; the message is kept
+// in progress meanwhile. Jobs submitted before priorities existed are
+// handed over as normal.
 func (c *NATSClient) SubscribeAllJobSubmits(ctx context.Context, handler func(domain.AnalysisJob)) error {
-	subject := "jobs.*.submit"
-	durableName := "worker-job-submit"
+	consumers, defaults := jobSubmitConsumers()
+	ackWait := 5 * time.Minute
+
+	for durableName, subject := range consumers {
+		cons, err := c.js.CreateOrUpdateConsumer(ctx, "JOBS", jetstream.ConsumerConfig{
+			Durable:       durableName,
+			FilterSubject: subject,
+			AckPolicy:     jetstream.AckExplicitPolicy,
+			DeliverPolicy: jetstream.DeliverAllPolicy,
+			MaxDeliver:    5,
+			AckWait:       ackWait,
+		})
+		if err != nil {
+			return fmt.Errorf("create consumer %s: %w", durableName, err)
+		}
+
+		// Pull one job at a time so that the jobs behind it stay in the
+		// stream, where a worker with a free slot can take them.
+		cc, err := cons.Consume(c.jobSubmitHandler(ackWait, defaults[durableName], handler), jetstream.PullMaxMessages(1))
+		if err != nil {
+			return fmt.Errorf("consume %s: %w", durableName, err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			cc.Stop()
+		}()
+	}
+
+	c.logger.Info("subscribed to all job submissions", "priorities", len(domain.JobPriorities))
+	return nil
+}
+
+// jobSubmitHandler decodes job submissions for handler and acknowledges
+// them once it returns. While the handler runs the message is marked in
+// progress every half ack wait so that it is not redelivered. Jobs without
+// a priority are given defaultPriority when it is set.
+func (c *NATSClient) jobSubmitHandler(ackWait time.Duration, defaultPriority domain.JobPriority, handler func(domain.AnalysisJob)) jetstream.MessageHandler {
+	return func(msg jetstream.Msg) {
+		subject := msg.Subject()
+		var job domain.AnalysisJob
+		if err := json.Unmarshal(msg.Data(), &job); err != nil {
+			c.logger.Error("unmarshal job submit", "error", err, "subject", subject)
+			// Terminal ack to avoid redelivery of malformed messages.
+			_ = msg.TermWithReason("unmarshal error")
+			return
+		}
+		if job.Priority == "" {
+			job.Priority = defaultPriority
+		}
+
+		done := make(chan struct{})
+		go func() {
+			ticker := time.NewTicker(ackWait / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if err := msg.InProgress(); err != nil {
+						c.logger.Warn("extend job submit ack wait", "error", err, "subject", subject)
+					}
+				}
+			}
+		}()
+		handler(job)
+		close(done)
+
+		if err := msg.Ack(); err != nil {
+			c.logger.Error("ack job submit", "error", err, "subject", subject)
+		}
+	}
+}
+
+// SubscribeAllExportSubmits creates a durable consumer for search export
+// requests across ALL tenants. Exports stream an entire result set, so the
+// ack wait is long enough to cover one export end to end.
+func (c *NATSClient) SubscribeAllExportSubmits(ctx context.Context, handler func(domain.SearchExport)) error {
+	subject := subjectExportSubmit(subjectAllTenants)
+	durableName := "worker-export-submit"
 
 	cons, err := c.js.CreateOrUpdateConsumer(ctx, "JOBS", jetstream.ConsumerConfig{
 		Durable:       durableName,
 		FilterSubject: subject,
 		AckPolicy:     jetstream.AckExplicitPolicy,
 		DeliverPolicy: jetstream.DeliverAllPolicy,
-		MaxDeliver:    5,
-		AckWait:       5 * time.Minute,
+		MaxDeliver:    3,
+		AckWait:       30 * time.Minute,
 	})
 	if err != nil {
 		return fmt.Errorf("create consumer %s: %w", durableName, err)
 	}
 
 	cc, err := cons.Consume(func(msg jetstream.Msg) {
-		var job domain.AnalysisJob
-		if err := json.Unmarshal(msg.Data(), &job); err != nil {
-			c.logger.Error("unmarshal job submit", "error", err, "subject", subject)
+		var export domain.SearchExport
+		if err := json.Unmarshal(msg.Data(), &export); err != nil {
+			c.logger.Error("unmarshal export submit", "error", err, "subject", subject)
 			_ = msg.TermWithReason("unmarshal error")
 			return
 		}
-		handler(job)
+		handler(export)
 		if err := msg.Ack(); err != nil {
-			c.logger.Error("ack job submit", "error", err, "subject", subject)
+			c.logger.Error("ack export submit", "error", err, "subject", subject)
 		}
 	})
 	if err != nil {
@@ -277,7 +502,7 @@ func (c *NATSClient) SubscribeAllJobSubmits(ctx context.Context, handler func(do
 		cc.Stop()
 	}()
 
-	c.logger.Info("subscribed to all job submissions", "durable", durableName)
+	c.logger.Info("subscribed to all export submissions", "durable", durableName)
 	return nil
 }
 
@@ -26,7 +26,7 @@ func natsURL(t *testing.T) string {
 
 func setupClient(t *testing.T) *NATSClient {
 	t.Helper()
-	client, err := NewNATSClient(natsURL(t))
+	client, err := NewNATSClient(natsURL(t), NATSAuth{})
 	require.NoError(t, err, "failed to connect to NATS")
 	t.Cleanup(func() { client.Close() })
 	return client
@@ -275,7 +275,20 @@ func TestJobComplete(t *testing.T) {
 	require.NoError(t, err, "publish job complete should not error")
 }
 
+func TestJobQueueDepth(t *testing.T) {
+	client := setupClient(t)
+	ctx := context.Background()
+	require.NoError(t, client.EnsureStreams(ctx))
+
+	depths, err := client.JobQueueDepth(ctx)
+	require.NoError(t, err)
+	for _, d := range depths {
+		assert.Contains(t, d.Consumer, "worker-job-submit")
+		assert.Contains(t, d.Subject, "jobs.*.submit")
+	}
+}
+
 func TestConnectionFailure(t *testing.T) {
-	_, err := NewNATSClient("nats://invalid-host:4222")
+	_, err := NewNATSClient("nats://invalid-host:4222", NATSAuth{})
 	assert.Error(t, err, "connecting to invalid host should fail")
 }
@@ -1,11 +1,19 @@
 package streaming
 
 import (
+	"context"
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -42,6 +50,30 @@ func TestSubjectJobSubmit(t *testing.T) {
 	}
 }
 
+func TestSubjectJobSubmitPriority(t *testing.T) {
+	assert.Equal(t, "jobs.tenant-1.submit.interactive", subjectJobSubmitPriority("tenant-1", domain.JobPriorityInteractive))
+	assert.Equal(t, "jobs.*.submit.batch", subjectJobSubmitPriority(subjectAllTenants, domain.JobPriorityBatch))
+
+	// Priority subjects must not overlap the legacy submit subject, as
+	// consumers of a work queue stream may not share subjects.
+	legacy := strings.Split(subjectJobSubmit(subjectAllTenants), ".")
+	for _, p := range domain.JobPriorities {
+		assert.NotEqual(t, len(legacy), len(strings.Split(subjectJobSubmitPriority(subjectAllTenants, p), ".")))
+	}
+}
+
+func TestJobSubmitConsumers(t *testing.T) {
+	subjects, priorities := jobSubmitConsumers()
+	require.Len(t, subjects, len(domain.JobPriorities)+1)
+	assert.Equal(t, "jobs.*.submit", subjects["worker-job-submit"])
+	assert.Equal(t, domain.JobPriorityNormal, priorities["worker-job-submit"])
+	for _, p := range domain.JobPriorities {
+		name := "worker-job-submit-" + string(p)
+		assert.Equal(t, subjectJobSubmitPriority(subjectAllTenants, p), subjects[name])
+		assert.Equal(t, p, priorities[name])
+	}
+}
+
 func TestSubjectJobProgress(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -97,6 +129,19 @@ func TestSubjectJobComplete(t *testing.T) {
 	}
 }
 
+func TestSubjectExportSubmit(t *testing.T) {
+	subject := subjectExportSubmit("tenant-xyz")
+	assert.Equal(t, "jobs.tenant-xyz.export", subject)
+
+	// Must not overlap the job submit filter on the JOBS work queue stream.
+	assert.NotEqual(t, "submit", splitDot(subject)[2])
+}
+
+func TestSubjectThresholdAlert(t *testing.T) {
+	assert.Equal(t, "alerts.tenant-xyz.threshold", subjectThresholdAlert("tenant-xyz"))
+	assert.Equal(t, "alerts.tenant-xyz.capture_drift", subjectCaptureDriftAlert("tenant-xyz"))
+}
+
 func TestSubjectLiveTail(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -154,6 +199,7 @@ func TestSubjectNamingPatterns(t *testing.T) {
 		assert.Contains(t, subjectJobSubmit(tenantID), "jobs.")
 		assert.Contains(t, subjectJobProgress(tenantID), "jobs.")
 		assert.Contains(t, subjectJobComplete(tenantID), "jobs.")
+		assert.Contains(t, subjectExportSubmit(tenantID), "jobs.")
 	})
 
 	t.Run("live tail subjects use the logs prefix", func(t *testing.T) {
@@ -221,6 +267,112 @@ func splitDot(s string) []string {
 	return parts
 }
 
+// ---------------------------------------------------------------------------
+// Stream subjects and tenant permissions
+// ---------------------------------------------------------------------------
+
+func TestStreamSubjects(t *testing.T) {
+	assert.Equal(t, []string{"jobs.*.submit", "jobs.*.submit.*", "jobs.*.progress", "jobs.*.complete", "jobs.*.export"}, jobsStreamSubjects())
+	assert.Equal(t, []string{"logs.*.tail.>", "ai.*.>", "alerts.*.threshold", "alerts.*.capture_drift"}, eventsStreamSubjects())
+}
+
+func TestTenantSubjectPermissions(t *testing.T) {
+	perms, err := TenantSubjectPermissions("tenant-a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jobs.tenant-a.>", "logs.tenant-a.>", "alerts.tenant-a.>", "ai.tenant-a.>"}, perms)
+
+	_, err = TenantSubjectPermissions("tenant.a")
+	assert.ErrorIs(t, err, ErrInvalidSubjectToken)
+}
+
+// ---------------------------------------------------------------------------
+// Publish-side tenant guard
+// ---------------------------------------------------------------------------
+
+func TestGuardTenant(t *testing.T) {
+	tests := []struct {
+		name          string
+		subjectTenant string
+		payloadTenant string
+		wantErr       error
+	}{
+		{"matching tenant", "tenant-a", "tenant-a", nil},
+		{"payload without tenant", "tenant-a", "", nil},
+		{"other tenant", "tenant-a", "tenant-b", ErrTenantMismatch},
+		{"empty subject tenant", "", "", ErrInvalidSubjectToken},
+		{"dot in tenant", "tenant.a", "tenant.a", ErrInvalidSubjectToken},
+		{"wildcard tenant", "*", "", ErrInvalidSubjectToken},
+		{"full wildcard tenant", ">", "", ErrInvalidSubjectToken},
+		{"whitespace in tenant", "tenant a", "", ErrInvalidSubjectToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guardTenant(tt.subjectTenant, tt.payloadTenant)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+// The guard runs before anything is sent, so a client without a connection
+// is enough to check that mismatched publishes are refused.
+func TestPublishRefusesOtherTenantsSubject(t *testing.T) {
+	c := &NATSClient{}
+	ctx := context.Background()
+	tenantA, tenantB := uuid.New(), uuid.New()
+
+	assert.ErrorIs(t, c.PublishJobSubmit(ctx, tenantA.String(), domain.AnalysisJob{TenantID: tenantB}), ErrTenantMismatch)
+	assert.ErrorIs(t, c.PublishJobComplete(ctx, tenantA.String(), "job-1", domain.AnalysisJob{TenantID: tenantB}), ErrTenantMismatch)
+	assert.ErrorIs(t, c.PublishExportSubmit(ctx, tenantA.String(), domain.SearchExport{TenantID: tenantB}), ErrTenantMismatch)
+	assert.ErrorIs(t, c.PublishThresholdViolation(ctx, tenantA.String(), domain.ThresholdViolation{TenantID: tenantB}), ErrTenantMismatch)
+	assert.ErrorIs(t, c.PublishCaptureDrift(ctx, tenantA.String(), domain.CaptureDriftEvent{TenantID: tenantB}), ErrTenantMismatch)
+	assert.ErrorIs(t, c.PublishLiveTailEntry(ctx, tenantA.String(), "API", domain.LogEntry{TenantID: tenantB.String()}), ErrTenantMismatch)
+	assert.ErrorIs(t, c.PublishLiveTailEntry(ctx, tenantA.String(), "API.>", domain.LogEntry{TenantID: tenantA.String()}), ErrInvalidSubjectToken)
+	assert.ErrorIs(t, c.PublishJobProgress(ctx, "*", "job-1", 10, "parsing", ""), ErrInvalidSubjectToken)
+}
+
+// ---------------------------------------------------------------------------
+// Credentials
+// ---------------------------------------------------------------------------
+
+func TestNewNATSClient_MisconfiguredCredentials(t *testing.T) {
+	badSeed := filepath.Join(t.TempDir(), "worker.nk")
+	require.NoError(t, os.WriteFile(badSeed, []byte("not a seed"), 0o600))
+
+	tests := []struct {
+		name    string
+		auth    NATSAuth
+		wantErr string
+	}{
+		{"missing creds file", NATSAuth{CredsFile: filepath.Join(t.TempDir(), "missing.creds")}, "creds file"},
+		{"invalid nkey seed", NATSAuth{NKeySeedFile: badSeed}, "nkey seed"},
+		{"user without password", NATSAuth{User: "worker"}, "password is required"},
+		{"two methods", NATSAuth{CredsFile: "/etc/nats/a.creds", User: "worker", Password: "x"}, "only one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The port is unreachable; the error must come from the
+			// credential check before any connection attempt.
+			_, err := NewNATSClient("nats://127.0.0.1:1", tt.auth)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestNATSAuthOptions(t *testing.T) {
+	opts, err := NATSAuth{}.options()
+	require.NoError(t, err)
+	assert.Empty(t, opts)
+
+	opts, err = NATSAuth{User: "api", Password: "s3cret"}.options()
+	require.NoError(t, err)
+	assert.Len(t, opts, 1)
+}
+
 // ---------------------------------------------------------------------------
 // JobProgress serialization tests
 // ---------------------------------------------------------------------------
@@ -318,6 +470,19 @@ func TestJobProgressJSONFieldNames(t *testing.T) {
 	assert.Contains(t, raw, `"message"`)
 }
 
+func TestJobProgressQueue(t *testing.T) {
+	data, err := json.Marshal(JobProgress{JobID: "j1", Status: "parsing"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"queue"`, "only queued jobs carry a queue estimate")
+
+	start := time.Date(2026, 2, 3, 10, 5, 0, 0, time.UTC)
+	data, err = json.Marshal(JobProgress{JobID: "j1", Status: "queued", Queue: &domain.JobQueueEstimate{
+		Position: 2, JobsAhead: 1, EstimatedStartAt: start, EstimatedWaitMS: 300000,
+	}})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"queue":{"position":2,"jobs_ahead":1,"estimated_start_at":"2026-02-03T10:05:00Z","estimated_wait_ms":300000}`)
+}
+
 func TestJobProgressRoundTrip(t *testing.T) {
 	original := JobProgress{
 		JobID:          "round-trip-job",
@@ -0,0 +1,134 @@
+package streaming
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// ProgressPublisher wraps a NATSStreamer and deduplicates job progress
+// updates. An update is published only when its percentage or status
+// changed, or when minInterval has passed since the last one for the job.
+// Percentages never go backwards, and terminal updates (complete, failed or
+// 100%) are always published. All other methods pass through unchanged.
+type ProgressPublisher struct {
+	NATSStreamer
+
+	minInterval time.Duration
+	settings    config.Dynamic
+	clock       clock.Clock
+
+	mu   sync.Mutex
+	jobs map[string]*jobProgressState
+}
+
+// jobProgressState is the last update sent for one job. Its mutex is held
+// across the publish so updates for a job go out in call order.
+type jobProgressState struct {
+	mu       sync.Mutex
+	pct      int
+	status   string
+	lastSent time.Time
+	sent     bool
+	done     bool
+}
+
+// NewProgressPublisher wraps inner. A minInterval of zero disables time
+// based re-publishing of unchanged updates.
+func NewProgressPublisher(inner NATSStreamer, minInterval time.Duration) *ProgressPublisher {
+	return &ProgressPublisher{
+		NATSStreamer: inner,
+		minInterval:  minInterval,
+		clock:        clock.Real,
+		jobs:         make(map[string]*jobProgressState),
+	}
+}
+
+// SetClock replaces the clock the interval between updates is measured on.
+func (p *ProgressPublisher) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// SetDynamic makes the publisher read its minimum interval from settings at
+// each update instead of using the one it was created with.
+func (p *ProgressPublisher) SetDynamic(settings config.Dynamic) {
+	p.settings = settings
+}
+
+func (p *ProgressPublisher) interval() time.Duration {
+	if p.settings != nil {
+		return p.settings.ProgressMinInterval()
+	}
+	return p.minInterval
+}
+
+// PublishJobProgress publishes a progress update unless it is a duplicate or
+// would move the job's percentage backwards. Skipped updates return nil.
+func (p *ProgressPublisher) PublishJobProgress(ctx context.Context, tenantID string, jobID string, progress int, status string, message string) error {
+	key := tenantID + "/" + jobID
+	if isTerminalProgress(progress, status) {
+		st := p.state(key)
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		st.done = true
+		p.forget(key)
+		return p.NATSStreamer.PublishJobProgress(ctx, tenantID, jobID, progress, status, message)
+	}
+
+	st := p.state(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.done {
+		return nil
+	}
+
+	now := p.clock.Now()
+	if st.sent {
+		if progress < st.pct {
+			return nil
+		}
+		unchanged := progress == st.pct && status == st.status
+		if minInterval := p.interval(); unchanged && (minInterval <= 0 || now.Sub(st.lastSent) < minInterval) {
+			return nil
+		}
+	}
+
+	if err := p.NATSStreamer.PublishJobProgress(ctx, tenantID, jobID, progress, status, message); err != nil {
+		return err
+	}
+	st.pct, st.status, st.lastSent, st.sent = progress, status, now, true
+	return nil
+}
+
+// PublishJobComplete publishes the completion event and drops the job's
+// progress state.
+func (p *ProgressPublisher) PublishJobComplete(ctx context.Context, tenantID string, jobID string, result domain.AnalysisJob) error {
+	p.forget(tenantID + "/" + jobID)
+	return p.NATSStreamer.PublishJobComplete(ctx, tenantID, jobID, result)
+}
+
+func (p *ProgressPublisher) state(key string) *jobProgressState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.jobs[key]
+	if !ok {
+		st = &jobProgressState{}
+		p.jobs[key] = st
+	}
+	return st
+}
+
+func (p *ProgressPublisher) forget(key string) {
+	p.mu.Lock()
+	delete(p.jobs, key)
+	p.mu.Unlock()
+}
+
+// isTerminalProgress reports whether an update ends a job's progress stream.
+func isTerminalProgress(progress int, status string) bool {
+	return progress >= 100 || domain.JobStatus(status).Terminal()
+}
@@ -0,0 +1,212 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// recordingStreamer records the progress updates that reach NATS.
+type recordingStreamer struct {
+	NATSStreamer
+
+	mu        sync.Mutex
+	published []JobProgress
+	completed []string
+	err       error
+}
+
+func (r *recordingStreamer) PublishJobProgress(_ context.Context, _ string, jobID string, progress int, status string, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.published = append(r.published, JobProgress{JobID: jobID, Status: status, ProgressPct: progress, Message: message})
+	return nil
+}
+
+func (r *recordingStreamer) PublishJobComplete(_ context.Context, _ string, jobID string, _ domain.AnalysisJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed = append(r.completed, jobID)
+	return nil
+}
+
+func (r *recordingStreamer) percentages() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]int, len(r.published))
+	for i, p := range r.published {
+		out[i] = p.ProgressPct
+	}
+	return out
+}
+
+func newTestProgressPublisher(minInterval time.Duration) (*ProgressPublisher, *recordingStreamer, *clock.Fake) {
+	inner := &recordingStreamer{}
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := NewProgressPublisher(inner, minInterval)
+	p.SetClock(fake)
+	return p, inner, fake
+}
+
+func TestProgressPublisher_CoalescesUnchangedUpdates(t *testing.T) {
+	p, inner, clock := newTestProgressPublisher(time.Second)
+	ctx := context.Background()
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", "processed 100 lines"))
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", "processed 200 lines"))
+	clock.Advance(500 * time.Millisecond)
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", "processed 300 lines"))
+	assert.Equal(t, []int{20}, inner.percentages(), "unchanged updates inside the interval are skipped")
+
+	clock.Advance(500 * time.Millisecond)
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", "processed 400 lines"))
+	assert.Equal(t, []int{20, 20}, inner.percentages(), "unchanged update is re-published once the interval passes")
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 21, "parsing", "processed 500 lines"))
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 21, "analyzing", "parsing JAR output"))
+	assert.Equal(t, []int{20, 20, 21, 21}, inner.percentages(), "percentage or status changes publish immediately")
+}
+
+func TestProgressPublisher_DynamicInterval(t *testing.T) {
+	p, inner, clock := newTestProgressPublisher(time.Minute)
+	settings := config.NewDynamicSettings(&config.Config{ProgressMinIntervalMS: 5000})
+	p.SetDynamic(settings)
+	ctx := context.Background()
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", ""))
+	clock.Advance(5 * time.Second)
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", ""))
+	assert.Equal(t, []int{20, 20}, inner.percentages(), "the interval of the settings, not the minute")
+
+	_, err := settings.Apply(&domain.ServerSettings{Version: 1, Settings: map[string]json.RawMessage{
+		"progress.min_interval_ms": json.RawMessage("10000"),
+	}})
+	require.NoError(t, err)
+	clock.Advance(5 * time.Second)
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 20, "parsing", ""))
+	assert.Equal(t, []int{20, 20}, inner.percentages(), "the changed interval applies to the next update")
+}
+
+func TestProgressPublisher_ZeroIntervalOnlyPublishesChanges(t *testing.T) {
+	p, inner, clock := newTestProgressPublisher(0)
+	ctx := context.Background()
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 30, "parsing", ""))
+	clock.Advance(time.Hour)
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 30, "parsing", ""))
+	assert.Equal(t, []int{30}, inner.percentages())
+}
+
+func TestProgressPublisher_NeverGoesBackwards(t *testing.T) {
+	p, inner, clock := newTestProgressPublisher(time.Second)
+	ctx := context.Background()
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 50, "parsing", ""))
+	clock.Advance(time.Minute)
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 40, "parsing", ""))
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 49, "analyzing", ""))
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 75, "analyzing", ""))
+	assert.Equal(t, []int{50, 75}, inner.percentages())
+}
+
+func TestProgressPublisher_TerminalAlwaysPublished(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress int
+		status   string
+	}{
+		{"failed at zero percent", 0, "failed"},
+		{"failed mid-way", 60, "failed"},
+		{"complete", 100, "complete"},
+		{"hundred percent", 100, "storing"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, inner, _ := newTestProgressPublisher(time.Hour)
+			ctx := context.Background()
+
+			require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 95, "storing", "log entries indexed"))
+			require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", tc.progress, tc.status, "done"))
+
+			require.Len(t, inner.published, 2)
+			last := inner.published[1]
+			assert.Equal(t, tc.progress, last.ProgressPct)
+			assert.Equal(t, tc.status, last.Status)
+			assert.Empty(t, p.jobs, "terminal update drops the job's state")
+		})
+	}
+}
+
+func TestProgressPublisher_FailedPublishIsRetried(t *testing.T) {
+	p, inner, _ := newTestProgressPublisher(time.Hour)
+	ctx := context.Background()
+
+	inner.err = errors.New("nats: connection closed")
+	require.Error(t, p.PublishJobProgress(ctx, "t1", "j1", 15, "parsing", ""))
+
+	inner.err = nil
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 15, "parsing", ""))
+	assert.Equal(t, []int{15}, inner.percentages())
+}
+
+func TestProgressPublisher_JobsAreIndependent(t *testing.T) {
+	p, inner, _ := newTestProgressPublisher(time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 50, "parsing", ""))
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j2", 10, "parsing", ""))
+	require.NoError(t, p.PublishJobProgress(ctx, "t2", "j1", 10, "parsing", ""))
+	assert.Equal(t, []int{50, 10, 10}, inner.percentages())
+}
+
+func TestProgressPublisher_CompleteDropsState(t *testing.T) {
+	p, inner, _ := newTestProgressPublisher(time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, p.PublishJobProgress(ctx, "t1", "j1", 95, "storing", ""))
+	require.NoError(t, p.PublishJobComplete(ctx, "t1", "j1", domain.AnalysisJob{}))
+	assert.Equal(t, []string{"j1"}, inner.completed)
+	assert.Empty(t, p.jobs)
+}
+
+func TestProgressPublisher_ConcurrentUpdates(t *testing.T) {
+	p, inner, _ := newTestProgressPublisher(time.Hour)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for job := 0; job < 4; job++ {
+		for pct := 1; pct < 100; pct++ {
+			wg.Add(1)
+			go func(jobID string, pct int) {
+				defer wg.Done()
+				_ = p.PublishJobProgress(ctx, "t1", jobID, pct, "parsing", "")
+			}(fmt.Sprintf("j%d", job), pct)
+		}
+	}
+	wg.Wait()
+
+	// Whatever order the goroutines ran in, each job's published
+	// percentages must be strictly increasing.
+	last := map[string]int{}
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	for _, pub := range inner.published {
+		assert.Greater(t, pub.ProgressPct, last[pub.JobID], "job %s went backwards", pub.JobID)
+		last[pub.JobID] = pub.ProgressPct
+	}
+	assert.Len(t, last, 4)
+}
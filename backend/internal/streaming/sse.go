@@ -0,0 +1,275 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// sseHeartbeatPeriod is how often an idle event stream sends a comment, so
+// that proxies do not close it.
+const sseHeartbeatPeriod = 15 * time.Second
+
+// Topics a client names when opening an event stream.
+const (
+	SSETopicJobProgress    = "job_progress"   // job_progress:<job_id>, with the job's job_complete
+	SSETopicLiveTail       = "live_tail"      // live_tail:<log_type>
+	SSETopicInvestigations = "investigations" // the tenant's investigation updates
+)
+
+// JobStateSource gives the current state of a job of a tenant. The hub keeps
+// no past events: an event stream resuming after a disconnection
+// synthesizes the job events the client missed from the job's state.
+type JobStateSource interface {
+	JobState(ctx context.Context, tenantID, jobID string) (*domain.AnalysisJob, error)
+}
+
+// SetJobStateSource enables the resumption of event streams. It must be
+// called before clients connect.
+func (h *Hub) SetJobStateSource(src JobStateSource) {
+	h.jobStates = src
+}
+
+// SSEClient is a client of the hub that receives its messages as
+// server-sent events, for browsers behind proxies that block WebSocket
+// upgrades. Its subscriptions are fixed when the stream opens; each event
+// is named after the type of the message and carries the message as its
+// data, as a WebSocket frame would. Dashboard streaming is not offered.
+type SSEClient struct {
+	*Client
+
+	// jobs are the job IDs of the client's job_progress subscriptions.
+	jobs      []string
+	heartbeat time.Duration
+}
+
+// NewSSEClient creates an event stream client speaking the given protocol
+// version and registers it with the hub. The caller subscribes it and then
+// runs Serve, or Close on failure.
+func NewSSEClient(hub *Hub, tenantID string, protocol int) *SSEClient {
+	c := newClient(hub, tenantID, protocol, "sse-client")
+	hub.register <- c
+	return &SSEClient{Client: c, heartbeat: sseHeartbeatPeriod}
+}
+
+// Subscribe subscribes the client to topics, a comma-separated list such as
+// "job_progress:<job_id>,live_tail:api,investigations". Topics are
+// validated as the WebSocket subscribe messages are, and the client may
+// hold as many subscriptions.
+func (s *SSEClient) Subscribe(topics string) error {
+	if strings.TrimSpace(topics) == "" {
+		return errors.New("topics is required")
+	}
+	for _, field := range strings.Split(topics, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(field), ":")
+		switch name {
+		case SSETopicJobProgress:
+			jobID, err := normalizeJobID(arg)
+			if err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+			if err := s.hub.subscribe(s.Client, jobProgressTopic(s.tenantID, jobID)); err != nil {
+				return err
+			}
+			if err := s.hub.subscribe(s.Client, jobCompleteTopic(s.tenantID, jobID)); err != nil {
+				return err
+			}
+			s.jobs = append(s.jobs, jobID)
+		case SSETopicLiveTail:
+			logType, err := normalizeLogType(arg)
+			if err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+			if err := s.hub.subscribe(s.Client, liveTailTopic(s.tenantID, logType)); err != nil {
+				return err
+			}
+		case SSETopicInvestigations:
+			if arg != "" {
+				return fmt.Errorf("%s: investigations takes no argument", field)
+			}
+			if err := s.hub.subscribe(s.Client, investigationsTopic(s.tenantID)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown topic %q", field)
+		}
+	}
+	return nil
+}
+
+// Resume queues the job events a client reconnecting with lastEventID
+// missed, synthesized from the current state of its jobs: a job_progress
+// for every job that moved on since the event, and a job_complete for
+// those that ended. It does nothing without a last event ID or a job state
+// source.
+func (s *SSEClient) Resume(ctx context.Context, lastEventID string) {
+	if lastEventID == "" || s.hub.jobStates == nil {
+		return
+	}
+	last, _ := parseEventID(lastEventID)
+	for _, jobID := range s.jobs {
+		job, err := s.hub.jobStates.JobState(ctx, s.tenantID, jobID)
+		if err != nil {
+			s.logger.Warn("job state not available to resume from", "job_id", jobID, "error", err)
+			continue
+		}
+		terminal := job.Status.Terminal()
+		if last.jobID == jobID && (last.complete || !terminal && job.ProgressPct <= last.progress) {
+			continue
+		}
+		progress := JobProgress{JobID: jobID, Status: string(job.Status), ProgressPct: job.ProgressPct}
+		if job.ProcessedLines != nil {
+			progress.ProcessedLines = *job.ProcessedLines
+		}
+		if job.TotalLines != nil {
+			progress.TotalLines = *job.TotalLines
+		}
+		s.sendJSON(ServerMessage{Type: MsgTypeJobProgress, Payload: progress})
+		if terminal {
+			s.sendJSON(ServerMessage{Type: MsgTypeJobComplete, Payload: job})
+		}
+	}
+}
+
+// Close unregisters the client. Serve closes it on return.
+func (s *SSEClient) Close() {
+	s.hub.unregister <- s.Client
+}
+
+// Serve writes the client's messages to w as an event stream until ctx is
+// done, typically when the client goes away, or a write fails. A comment
+// is sent whenever the stream was idle for the heartbeat period.
+func (s *SSEClient) Serve(ctx context.Context, w http.ResponseWriter) {
+	defer s.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+	w.WriteHeader(http.StatusOK)
+	if err := flushSSE(rc); err != nil {
+		return
+	}
+
+	ticker := s.hub.clock.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case message, ok := <-s.send:
+			if !ok {
+				return
+			}
+			_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := writeSSEEvent(w, message); err != nil {
+				return
+			}
+			// Write the messages queued meanwhile before flushing.
+			n := len(s.send)
+			for i := 0; i < n; i++ {
+				if err := writeSSEEvent(w, <-s.send); err != nil {
+					return
+				}
+			}
+			if err := flushSSE(rc); err != nil {
+				return
+			}
+			ticker.Reset(s.heartbeat)
+
+		case <-ticker.C():
+			_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := flushSSE(rc); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func flushSSE(rc *http.ResponseController) error {
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// writeSSEEvent writes a queued message as an event named after its type.
+// Job events carry an event ID, which the browser sends back as
+// Last-Event-ID when it reconnects.
+func writeSSEEvent(w io.Writer, message []byte) error {
+	var msg struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return fmt.Errorf("decode queued message: %w", err)
+	}
+	var b strings.Builder
+	if id := eventID(msg.Type, msg.Payload); id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	b.WriteString("event: " + msg.Type + "\n")
+	b.WriteString("data: ")
+	b.Write(message)
+	b.WriteString("\n\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// eventID returns the event ID of a job event: job_progress:<job_id>:<pct>
+// or job_complete:<job_id>. Other events have none.
+func eventID(msgType string, payload json.RawMessage) string {
+	switch msgType {
+	case MsgTypeJobProgress:
+		var p JobProgress
+		if json.Unmarshal(payload, &p) != nil || p.JobID == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s:%s:%d", MsgTypeJobProgress, p.JobID, p.ProgressPct)
+	case MsgTypeJobComplete:
+		var job struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(payload, &job) != nil || job.ID == "" {
+			return ""
+		}
+		return MsgTypeJobComplete + ":" + job.ID
+	}
+	return ""
+}
+
+// lastEvent is the job event an event ID names.
+type lastEvent struct {
+	jobID    string
+	progress int
+	complete bool
+}
+
+// parseEventID reads an event ID made by eventID.
+func parseEventID(id string) (lastEvent, bool) {
+	parts := strings.Split(id, ":")
+	switch {
+	case len(parts) == 3 && parts[0] == MsgTypeJobProgress:
+		pct, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return lastEvent{}, false
+		}
+		return lastEvent{jobID: parts[1], progress: pct}, true
+	case len(parts) == 2 && parts[0] == MsgTypeJobComplete:
+		return lastEvent{jobID: parts[1], complete: true}, true
+	}
+	return lastEvent{}, false
+}
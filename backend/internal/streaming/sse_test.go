@@ -0,0 +1,232 @@
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const sseJobID = "44444444-4444-4444-4444-444444444444"
+
+// fakeJobStates serves the jobs it holds, keyed by job ID.
+type fakeJobStates map[string]*domain.AnalysisJob
+
+func (f fakeJobStates) JobState(_ context.Context, _, jobID string) (*domain.AnalysisJob, error) {
+	job, ok := f[jobID]
+	if !ok {
+		return nil, errors.New("analysis job not found")
+	}
+	return job, nil
+}
+
+// sseTestServer serves event streams of tenant "sse-tenant" as the API's
+// handler does, with the given heartbeat period.
+func sseTestServer(t *testing.T, hub *Hub, heartbeat time.Duration) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := NewSSEClient(hub, "sse-tenant", ProtocolV2)
+		client.heartbeat = heartbeat
+		if err := client.Subscribe(r.URL.Query().Get("topics")); err != nil {
+			client.Close()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		client.Resume(r.Context(), r.Header.Get("Last-Event-ID"))
+		client.Serve(r.Context(), w)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openSSE opens an event stream on topics, resuming after lastEventID
+// unless it is empty.
+func openSSE(t *testing.T, server *httptest.Server, topics, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?topics="+topics, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readFrame reads the lines of the next event stream frame.
+func readFrame(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+	type result struct {
+		lines []string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				done <- result{lines, err}
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				done <- result{lines, nil}
+				return
+			}
+			lines = append(lines, line)
+		}
+	}()
+	select {
+	case res := <-done:
+		require.NoError(t, res.err)
+		return res.lines
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event stream frame within 2s")
+		return nil
+	}
+}
+
+func TestSSEClient_EventFraming(t *testing.T) {
+	hub := startTestHub(t)
+	server := sseTestServer(t, hub, time.Minute)
+
+	resp, r := openSSE(t, server, "job_progress:"+sseJobID+",live_tail:api", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	hub.Broadcast(jobProgressTopic("sse-tenant", sseJobID), ServerMessage{
+		Type:    MsgTypeJobProgress,
+		Payload: JobProgress{JobID: sseJobID, Status: "parsing", ProgressPct: 42},
+	})
+	assert.Equal(t, []string{
+		"id: job_progress:" + sseJobID + ":42",
+		"event: job_progress",
+		`data: {"type":"job_progress","version":2,"payload":{"job_id":"` + sseJobID + `","status":"parsing","progress_pct":42,"processed_lines":0,"total_lines":0,"message":""}}`,
+	}, readFrame(t, r))
+
+	// Events that cannot be resumed carry no ID.
+	hub.Broadcast(liveTailTopic("sse-tenant", "api"), ServerMessage{Type: MsgTypeLiveTailEntry, Payload: map[string]string{"entry_id": "e1"}})
+	assert.Equal(t, []string{
+		"event: live_tail_entry",
+		`data: {"type":"live_tail_entry","version":2,"payload":{"entry_id":"e1"}}`,
+	}, readFrame(t, r))
+}
+
+func TestSSEClient_Heartbeat(t *testing.T) {
+	hub, fake := startFakeClockHub(t)
+	server := sseTestServer(t, hub, time.Minute)
+
+	_, r := openSSE(t, server, "investigations", "")
+	fake.BlockUntil(1)
+	for i := 0; i < 2; i++ {
+		fake.Advance(time.Minute)
+		assert.Equal(t, []string{": heartbeat"}, readFrame(t, r))
+	}
+}
+
+func TestSSEClient_Resume(t *testing.T) {
+	running := &domain.AnalysisJob{Status: domain.JobStatusParsing, ProgressPct: 60}
+	hub := startTestHub(t)
+	hub.SetJobStateSource(fakeJobStates{sseJobID: running})
+	server := sseTestServer(t, hub, 50*time.Millisecond)
+
+	t.Run("missed progress is synthesized from the job's state", func(t *testing.T) {
+		_, r := openSSE(t, server, "job_progress:"+sseJobID, "job_progress:"+sseJobID+":40")
+		frame := readFrame(t, r)
+		require.Len(t, frame, 3)
+		assert.Equal(t, "id: job_progress:"+sseJobID+":60", frame[0])
+		assert.Equal(t, "event: job_progress", frame[1])
+		assert.Contains(t, frame[2], `"status":"parsing","progress_pct":60`)
+	})
+
+	t.Run("nothing is replayed when the client is up to date", func(t *testing.T) {
+		_, r := openSSE(t, server, "job_progress:"+sseJobID, "job_progress:"+sseJobID+":60")
+		assert.Equal(t, []string{": heartbeat"}, readFrame(t, r))
+	})
+
+	t.Run("a fresh stream is not replayed", func(t *testing.T) {
+		_, r := openSSE(t, server, "job_progress:"+sseJobID, "")
+		assert.Equal(t, []string{": heartbeat"}, readFrame(t, r))
+	})
+
+	t.Run("a job that ended is completed", func(t *testing.T) {
+		jobID := "55555555-5555-5555-5555-555555555555"
+		hub := startTestHub(t)
+		hub.SetJobStateSource(fakeJobStates{jobID: {ID: uuid.MustParse(jobID), Status: domain.JobStatusComplete, ProgressPct: 100}})
+		server := sseTestServer(t, hub, time.Minute)
+
+		// The last event seen was of another job.
+		_, r := openSSE(t, server, "job_progress:"+jobID, "job_progress:"+sseJobID+":60")
+		assert.Equal(t, "id: job_progress:"+jobID+":100", readFrame(t, r)[0])
+		assert.Equal(t, []string{"id: job_complete:" + jobID, "event: job_complete"}, readFrame(t, r)[:2])
+	})
+}
+
+func TestSSEClient_Subscribe(t *testing.T) {
+	hub := startTestHub(t)
+	server := sseTestServer(t, hub, time.Minute)
+
+	tooMany := make([]string, maxSubscriptions/2+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("job_progress:%08d-0000-0000-0000-000000000000", i)
+	}
+	for name, topics := range map[string]string{
+		"no topics":          "",
+		"unknown topic":      "dashboard:" + sseJobID,
+		"invalid job ID":     "job_progress:not-a-job",
+		"unknown log type":   "live_tail:syslog",
+		"argument to tenant": "investigations:other-tenant",
+		"too many":           strings.Join(tooMany, ","),
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, _ := openSSE(t, server, topics, "")
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+	require.Eventually(t, func() bool { return hub.totalClients() == 0 }, time.Second, 10*time.Millisecond,
+		"rejected clients are unregistered")
+}
+
+func TestSSEClient_UnregistersOnDisconnect(t *testing.T) {
+	hub := startTestHub(t)
+	server := sseTestServer(t, hub, time.Minute)
+
+	resp, _ := openSSE(t, server, "job_progress:"+sseJobID, "")
+	require.Equal(t, 1, hub.totalClients())
+	resp.Body.Close()
+
+	require.Eventually(t, func() bool { return hub.totalClients() == 0 }, 2*time.Second, 10*time.Millisecond)
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	assert.Empty(t, hub.topics)
+}
+
+func TestEventID(t *testing.T) {
+	last, ok := parseEventID(eventID(MsgTypeJobProgress, []byte(`{"job_id":"j1","progress_pct":35}`)))
+	require.True(t, ok)
+	assert.Equal(t, lastEvent{jobID: "j1", progress: 35}, last)
+
+	last, ok = parseEventID(eventID(MsgTypeJobComplete, []byte(`{"id":"j1","status":"complete"}`)))
+	require.True(t, ok)
+	assert.Equal(t, lastEvent{jobID: "j1", complete: true}, last)
+
+	assert.Empty(t, eventID(MsgTypeInvestigation, []byte(`{"job_id":"j1"}`)))
+	_, ok = parseEventID("job_progress:j1:many")
+	assert.False(t, ok)
+}
@@ -0,0 +1,146 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// Subject taxonomy. Every subject is <root>.<tenant_id>.<kind>[.<detail>],
+// so a credential can be confined to one tenant with permissions on
+// "<root>.<tenant_id>.>" (see TenantSubjectPermissions), and the services
+// that serve every tenant subscribe with the "*" wildcard in the tenant
+// position.
+const (
+	// subjectRootJobs carries job lifecycle events and export requests
+	// (JOBS stream).
+	subjectRootJobs = "jobs"
+	// subjectRootLogs carries live tail entries (EVENTS stream).
+	subjectRootLogs = "logs"
+	// subjectRootAlerts carries threshold and capture drift alerts
+	// (EVENTS stream).
+	subjectRootAlerts = "alerts"
+	// subjectRootAI is reserved for AI streaming events (EVENTS stream).
+	subjectRootAI = "ai"
+
+	subjectKindSubmit    = "submit"
+	subjectKindProgress  = "progress"
+	subjectKindComplete  = "complete"
+	subjectKindExport    = "export"
+	subjectKindThreshold = "threshold"
+	subjectKindDrift     = "capture_drift"
+	subjectKindTail      = "tail"
+
+	// subjectAllTenants is the wildcard used in the tenant position by
+	// consumers that serve every tenant.
+	subjectAllTenants = "*"
+)
+
+var (
+	// ErrInvalidSubjectToken is returned when a tenant ID cannot be used as
+	// a subject token: it is empty or contains '.', '*', '>' or whitespace.
+	ErrInvalidSubjectToken = errors.New("streaming: invalid subject token")
+
+	// ErrTenantMismatch is returned when a message is published to another
+	// tenant's subject than the tenant the payload belongs to.
+	ErrTenantMismatch = errors.New("streaming: payload tenant does not match subject tenant")
+)
+
+// tenantSubject joins root, tenant and the remaining tokens into a subject.
+func tenantSubject(root, tenantID string, tokens ...string) string {
+	return strings.Join(append([]string{root, tenantID}, tokens...), ".")
+}
+
+func subjectJobSubmit(tenantID string) string {
+	return tenantSubject(subjectRootJobs, tenantID, subjectKindSubmit)
+}
+
+// subjectJobSubmitPriority is the submit subject of jobs of one priority.
+// Jobs published before priorities existed are on subjectJobSubmit.
+func subjectJobSubmitPriority(tenantID string, priority domain.JobPriority) string {
+	return tenantSubject(subjectRootJobs, tenantID, subjectKindSubmit, string(priority))
+}
+
+func subjectJobProgress(tenantID string) string {
+	return tenantSubject(subjectRootJobs, tenantID, subjectKindProgress)
+}
+
+func subjectJobComplete(tenantID string) string {
+	return tenantSubject(subjectRootJobs, tenantID, subjectKindComplete)
+}
+
+func subjectExportSubmit(tenantID string) string {
+	return tenantSubject(subjectRootJobs, tenantID, subjectKindExport)
+}
+
+func subjectThresholdAlert(tenantID string) string {
+	return tenantSubject(subjectRootAlerts, tenantID, subjectKindThreshold)
+}
+
+func subjectCaptureDriftAlert(tenantID string) string {
+	return tenantSubject(subjectRootAlerts, tenantID, subjectKindDrift)
+}
+
+func subjectLiveTail(tenantID, logType string) string {
+	return tenantSubject(subjectRootLogs, tenantID, subjectKindTail, logType)
+}
+
+// jobsStreamSubjects are the subjects captured by the JOBS stream. They are
+// listed per kind rather than as "jobs.>" so that stray subjects are never
+// persisted.
+func jobsStreamSubjects() []string {
+	return []string{
+		subjectJobSubmit(subjectAllTenants),
+		subjectJobSubmitPriority(subjectAllTenants, "*"),
+		subjectJobProgress(subjectAllTenants),
+		subjectJobComplete(subjectAllTenants),
+		subjectExportSubmit(subjectAllTenants),
+	}
+}
+
+// eventsStreamSubjects are the subjects captured by the EVENTS stream.
+func eventsStreamSubjects() []string {
+	return []string{
+		subjectLiveTail(subjectAllTenants, ">"),
+		tenantSubject(subjectRootAI, subjectAllTenants, ">"),
+		subjectThresholdAlert(subjectAllTenants),
+		subjectCaptureDriftAlert(subjectAllTenants),
+	}
+}
+
+// TenantSubjectPermissions returns the subjects a credential scoped to one
+// tenant should be allowed to publish and subscribe to.
+func TenantSubjectPermissions(tenantID string) ([]string, error) {
+	if err := validateSubjectToken(tenantID); err != nil {
+		return nil, err
+	}
+	var perms []string
+	for _, root := range []string{subjectRootJobs, subjectRootLogs, subjectRootAlerts, subjectRootAI} {
+		perms = append(perms, tenantSubject(root, tenantID, ">"))
+	}
+	return perms, nil
+}
+
+// validateSubjectToken reports whether token can be used as a single
+// subject token.
+func validateSubjectToken(token string) error {
+	if token == "" || strings.ContainsAny(token, ".*> \t\r\n") {
+		return fmt.Errorf("%w: %q", ErrInvalidSubjectToken, token)
+	}
+	return nil
+}
+
+// guardTenant is the publish-side check that the tenant a subject is built
+// for is a valid token and, when the payload carries a tenant, that it is
+// the same tenant. payloadTenant is empty for payloads without a tenant.
+func guardTenant(subjectTenant, payloadTenant string) error {
+	if err := validateSubjectToken(subjectTenant); err != nil {
+		return err
+	}
+	if payloadTenant != "" && payloadTenant != subjectTenant {
+		return fmt.Errorf("%w: subject tenant %s, payload tenant %s", ErrTenantMismatch, subjectTenant, payloadTenant)
+	}
+	return nil
+}
@@ -4,10 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -35,16 +41,35 @@ const (
 	maxSubscriptions = 10
 )
 
+// ---------------------------------------------------------------------------
+// Protocol versions
+// ---------------------------------------------------------------------------
+
+// Versions of the WebSocket protocol. Clients that do not ask for a version
+// speak version 1, whose envelopes carry no version field; from version 2
+// on every server message names the version of the connection.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+)
+
+// SupportedProtocols are the protocol versions the hub speaks, oldest first.
+var SupportedProtocols = []int{ProtocolV1, ProtocolV2}
+
 // ---------------------------------------------------------------------------
 // Client-to-server message types
 // ---------------------------------------------------------------------------
 
 const (
-	MsgTypeSubscribeJobProgress   = "subscribe_job_progress"
-	MsgTypeUnsubscribeJobProgress = "unsubscribe_job_progress"
-	MsgTypeSubscribeLiveTail      = "subscribe_live_tail"
-	MsgTypeUnsubscribeLiveTail    = "unsubscribe_live_tail"
-	MsgTypePing                   = "ping"
+	MsgTypeSubscribeJobProgress      = "subscribe_job_progress"
+	MsgTypeUnsubscribeJobProgress    = "unsubscribe_job_progress"
+	MsgTypeSubscribeLiveTail         = "subscribe_live_tail"
+	MsgTypeUnsubscribeLiveTail       = "unsubscribe_live_tail"
+	MsgTypeSubscribeInvestigations   = "subscribe_investigations"
+	MsgTypeUnsubscribeInvestigations = "unsubscribe_investigations"
+	MsgTypeSubscribeDashboard        = "subscribe_dashboard"
+	MsgTypeUnsubscribeDashboard      = "unsubscribe_dashboard"
+	MsgTypePing                      = "ping"
 )
 
 // ---------------------------------------------------------------------------
@@ -52,11 +77,14 @@ const (
 // ---------------------------------------------------------------------------
 
 const (
-	MsgTypeJobProgress   = "job_progress"
-	MsgTypeJobComplete   = "job_complete"
-	MsgTypeLiveTailEntry = "live_tail_entry"
-	MsgTypeError         = "error"
-	MsgTypePong          = "pong"
+	MsgTypeJobProgress       = "job_progress"
+	MsgTypeJobComplete       = "job_complete"
+	MsgTypeLiveTailEntry     = "live_tail_entry"
+	MsgTypeInvestigation     = "investigation_updated"
+	MsgTypeDashboardSection  = "dashboard_section"
+	MsgTypeDashboardComplete = "dashboard_complete"
+	MsgTypeError             = "error"
+	MsgTypePong              = "pong"
 )
 
 // ---------------------------------------------------------------------------
@@ -70,8 +98,10 @@ type ClientMessage struct {
 }
 
 // ServerMessage is the envelope for all server-to-client WebSocket messages.
+// Version is set per client on sending and left empty for version 1.
 type ServerMessage struct {
 	Type    string `json:"type"`
+	Version int    `json:"version,omitempty"`
 	Payload any    `json:"payload,omitempty"`
 }
 
@@ -108,6 +138,17 @@ type Hub struct {
 	unregister chan *Client
 	broadcast  chan topicMessage
 
+	// dashboards serves subscribe_dashboard; nil disables it.
+	dashboards           DashboardSource
+	dashboardParallelism int
+
+	// jobStates resumes event streams; nil disables it.
+	jobStates JobStateSource
+
+	// clock times the clients' rate limits, pings and heartbeats. Network
+	// deadlines always use the system clock.
+	clock clock.Clock
+
 	mu     sync.RWMutex
 	logger *slog.Logger
 }
@@ -126,10 +167,16 @@ func NewHub() *Hub {
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		broadcast:  make(chan topicMessage, 256),
+		clock:      clock.Real,
 		logger:     slog.Default().With("component", "ws-hub"),
 	}
 }
 
+// SetClock replaces the clock of the hub and of the clients created after.
+func (h *Hub) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
 // Run starts the hub event loop. It must be called in a dedicated goroutine.
 func (h *Hub) Run() {
 	for {
@@ -160,7 +207,6 @@ func (h *Hub) addClient(c *Client) {
 	h.logger.Info("client registered", "tenant", c.tenantID, "total_clients", h.totalClientsNoLock())
 }
 
-
 func (h *Hub) removeClient(c *Client) {
 	h.mu.Lock()
 
@@ -192,7 +238,7 @@ func (h *Hub) removeClient(c *Client) {
 	}
 	h.mu.Unlock()
 
-	close(c.send)
+	c.closeSend()
 
 	h.logger.Info("client unregistered", "tenant", c.tenantID, "total_clients", h.totalClients())
 }
@@ -229,31 +275,44 @@ func (h *Hub) broadcastToTopic(tm topicMessage) {
 	}
 	h.mu.RUnlock()
 
-	data, err := json.Marshal(tm.message)
-	if err != nil {
-		h.logger.Error("marshal broadcast message", "error", err, "topic", tm.topic)
-		return
+	// Marshal once per protocol version among the subscribers.
+	encoded := make(map[int][]byte, len(SupportedProtocols))
+	for _, c := range targets {
+		data, ok := encoded[c.protocol]
+		if !ok {
+			var err error
+			data, err = json.Marshal(c.envelope(tm.message))
+			if err != nil {
+				h.logger.Error("marshal broadcast message", "error", err, "topic", tm.topic)
+				return
+			}
+			encoded[c.protocol] = data
+		}
+		h.enqueueNewest(c, data, tm.topic)
 	}
+}
 
-	for _, c := range targets {
+// enqueueNewest queues data on the client's send buffer. When the buffer is
+// full the oldest queued messages are dropped until data fits, so a slow
+// client always receives the latest update -- for job progress that is the
+// terminal complete/failed message. Other writers may refill the buffer
+// concurrently, so the number of attempts is bounded.
+func (h *Hub) enqueueNewest(c *Client, data []byte, topic string) {
+	for attempt := 0; attempt <= cap(c.send); attempt++ {
 		select {
 		case c.send <- data:
+			return
+		default:
+		}
+		select {
+		case <-c.send:
+			h.logger.Warn("dropped oldest message due to backpressure",
+				"tenant", c.tenantID, "topic", topic)
 		default:
-			// Buffer full -- drop oldest by draining one and retrying once.
-			select {
-			case <-c.send:
-				h.logger.Warn("dropped oldest message due to backpressure",
-					"tenant", c.tenantID, "topic", tm.topic)
-			default:
-			}
-			select {
-			case c.send <- data:
-			default:
-				h.logger.Warn("message dropped, client too slow",
-					"tenant", c.tenantID, "topic", tm.topic)
-			}
 		}
 	}
+	h.logger.Warn("message dropped, client too slow",
+		"tenant", c.tenantID, "topic", topic)
 }
 
 // Broadcast sends a message to all clients subscribed to the given topic.
@@ -261,6 +320,36 @@ func (h *Hub) Broadcast(topic string, msg ServerMessage) {
 	h.broadcast <- topicMessage{topic: topic, message: msg}
 }
 
+// NegotiateProtocol picks the protocol version of a new client: the highest
+// version both the hub and the client speak. offered is the client's
+// comma-separated list of versions, e.g. "1,2"; an empty list is a client
+// predating versioning, which speaks version 1.
+func (h *Hub) NegotiateProtocol(offered string) (int, error) {
+	if strings.TrimSpace(offered) == "" {
+		return ProtocolV1, nil
+	}
+	best := 0
+	for _, field := range strings.Split(offered, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return 0, fmt.Errorf("invalid protocol version %q", field)
+		}
+		if v > best && slices.Contains(SupportedProtocols, v) {
+			best = v
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("no supported protocol version in %q; the server speaks %v", offered, SupportedProtocols)
+	}
+	return best, nil
+}
+
+// BroadcastInvestigation tells a tenant's open dashboards that the
+// investigation of an analysis changed.
+func (h *Hub) BroadcastInvestigation(tenantID string, event domain.InvestigationEvent) {
+	h.Broadcast(investigationsTopic(tenantID), ServerMessage{Type: MsgTypeInvestigation, Payload: event})
+}
+
 // subscribe adds a client to a topic. Returns an error if the client has
 // reached the maximum number of concurrent subscriptions.
 //
@@ -315,11 +404,16 @@ func (h *Hub) unsubscribe(c *Client, topic string) {
 // Client
 // ---------------------------------------------------------------------------
 
-// Client represents a single WebSocket connection bound to a tenant.
+// Client is the hub's end of a connection bound to a tenant: its topic
+// subscriptions and the queue of messages for it. The hub does not depend
+// on the transport. A WebSocket connection is driven by ReadPump and
+// WritePump; an SSEClient streams the queue as server-sent events and
+// leaves conn nil.
 type Client struct {
 	hub      *Hub
 	conn     *websocket.Conn
 	tenantID string
+	protocol int
 
 	// Buffered channel of outbound messages (serialized JSON bytes).
 	send chan []byte
@@ -328,23 +422,44 @@ type Client struct {
 	subscriptions map[string]struct{}
 	subsMu        sync.Mutex
 
+	// Read-side abuse controls, only used from the read pump.
+	limiter *messageLimiter
+	churn   churnCounter
+
+	// dashboard is the dashboard stream of subscribe_dashboard.
+	dashboard dashboardStream
+
 	logger *slog.Logger
 }
 
-// NewClient creates a new WebSocket client, registers it with the hub, and
-// returns it. The caller must start ReadPump and WritePump in separate
-// goroutines.
+// NewClient creates a new WebSocket client speaking protocol version 1,
+// registers it with the hub, and returns it. The caller must start ReadPump
+// and WritePump in separate goroutines.
 func NewClient(hub *Hub, conn *websocket.Conn, tenantID string) *Client {
-	c := &Client{
+	return NewClientWithProtocol(hub, conn, tenantID, ProtocolV1)
+}
+
+// NewClientWithProtocol is NewClient for a client that negotiated the given
+// protocol version with Hub.NegotiateProtocol.
+func NewClientWithProtocol(hub *Hub, conn *websocket.Conn, tenantID string, protocol int) *Client {
+	c := newClient(hub, tenantID, protocol, "ws-client")
+	c.conn = conn
+	hub.register <- c
+	return c
+}
+
+// newClient creates a client of the hub without a transport; the caller
+// attaches one and registers the client.
+func newClient(hub *Hub, tenantID string, protocol int, component string) *Client {
+	return &Client{
 		hub:           hub,
-		conn:          conn,
 		tenantID:      tenantID,
+		protocol:      protocol,
 		send:          make(chan []byte, sendBufferSize),
 		subscriptions: make(map[string]struct{}),
-		logger:        slog.Default().With("component", "ws-client", "tenant", tenantID),
+		limiter:       newMessageLimiter(hub.clock.Now()),
+		logger:        slog.Default().With("component", component, "tenant", tenantID),
 	}
-	hub.register <- c
-	return c
 }
 
 // ReadPump reads messages from the WebSocket connection and dispatches them.
@@ -371,6 +486,19 @@ func (c *Client) ReadPump() {
 			}
 			return
 		}
+
+		ok, abusive := c.limiter.allow(c.hub.clock.Now())
+		if abusive {
+			c.logger.Warn("closing connection: message rate limit exceeded")
+			_ = c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "message rate limit exceeded"),
+				time.Now().Add(writeWait))
+			return
+		}
+		if !ok {
+			c.sendError("RATE_LIMITED", "too many messages")
+			continue
+		}
 		c.handleMessage(raw)
 	}
 }
@@ -382,7 +510,7 @@ func (c *Client) ReadPump() {
 // Each queued message is sent as a separate WebSocket text frame so that
 // the client can JSON.parse each frame individually.
 func (c *Client) WritePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := c.hub.clock.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -411,7 +539,7 @@ func (c *Client) WritePump() {
 				}
 			}
 
-		case <-ticker.C:
+		case <-ticker.C():
 			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
@@ -429,6 +557,16 @@ func (c *Client) handleMessage(raw []byte) {
 		return
 	}
 
+	switch msg.Type {
+	case MsgTypeSubscribeJobProgress, MsgTypeUnsubscribeJobProgress,
+		MsgTypeSubscribeLiveTail, MsgTypeUnsubscribeLiveTail,
+		MsgTypeSubscribeInvestigations, MsgTypeUnsubscribeInvestigations,
+		MsgTypeSubscribeDashboard, MsgTypeUnsubscribeDashboard:
+		if n, warn := c.churn.add(c.hub.clock.Now()); warn {
+			c.logger.Warn("high subscription churn", "changes", n, "window", churnWindow)
+		}
+	}
+
 	switch msg.Type {
 	case MsgTypePing:
 		c.sendJSON(ServerMessage{Type: MsgTypePong})
@@ -445,6 +583,20 @@ func (c *Client) handleMessage(raw []byte) {
 	case MsgTypeUnsubscribeLiveTail:
 		c.handleUnsubscribeLiveTail(msg.Payload)
 
+	case MsgTypeSubscribeInvestigations:
+		if err := c.hub.subscribe(c, investigationsTopic(c.tenantID)); err != nil {
+			c.sendError("SUBSCRIBE_FAILED", err.Error())
+		}
+
+	case MsgTypeUnsubscribeInvestigations:
+		c.hub.unsubscribe(c, investigationsTopic(c.tenantID))
+
+	case MsgTypeSubscribeDashboard:
+		c.handleSubscribeDashboard(msg.Payload)
+
+	case MsgTypeUnsubscribeDashboard:
+		c.stopDashboard()
+
 	default:
 		c.sendError("UNKNOWN_TYPE", fmt.Sprintf("unknown message type: %s", msg.Type))
 	}
@@ -456,18 +608,23 @@ func (c *Client) handleSubscribeJobProgress(payload json.RawMessage) {
 		c.sendError("INVALID_PAYLOAD", "job_id is required for subscribe_job_progress")
 		return
 	}
+	jobID, err := normalizeJobID(p.JobID)
+	if err != nil {
+		c.sendError("INVALID_PAYLOAD", err.Error())
+		return
+	}
 
-	topic := jobProgressTopic(c.tenantID, p.JobID)
+	topic := jobProgressTopic(c.tenantID, jobID)
 	if err := c.hub.subscribe(c, topic); err != nil {
 		c.sendError("SUBSCRIBE_FAILED", err.Error())
 		return
 	}
 
 	// Also subscribe to job complete for this job.
-	completeTopic := jobCompleteTopic(c.tenantID, p.JobID)
+	completeTopic := jobCompleteTopic(c.tenantID, jobID)
 	if err := c.hub.subscribe(c, completeTopic); err != nil {
 		// Non-fatal: progress subscription already succeeded.
-		c.logger.Warn("failed to subscribe to job complete", "error", err, "job_id", p.JobID)
+		c.logger.Warn("failed to subscribe to job complete", "error", err, "job_id", jobID)
 	}
 }
 
@@ -477,9 +634,14 @@ func (c *Client) handleUnsubscribeJobProgress(payload json.RawMessage) {
 		c.sendError("INVALID_PAYLOAD", "job_id is required for unsubscribe_job_progress")
 		return
 	}
+	jobID, err := normalizeJobID(p.JobID)
+	if err != nil {
+		c.sendError("INVALID_PAYLOAD", err.Error())
+		return
+	}
 
-	c.hub.unsubscribe(c, jobProgressTopic(c.tenantID, p.JobID))
-	c.hub.unsubscribe(c, jobCompleteTopic(c.tenantID, p.JobID))
+	c.hub.unsubscribe(c, jobProgressTopic(c.tenantID, jobID))
+	c.hub.unsubscribe(c, jobCompleteTopic(c.tenantID, jobID))
 }
 
 func (c *Client) handleSubscribeLiveTail(payload json.RawMessage) {
@@ -488,8 +650,13 @@ func (c *Client) handleSubscribeLiveTail(payload json.RawMessage) {
 		c.sendError("INVALID_PAYLOAD", "log_type is required for subscribe_live_tail")
 		return
 	}
+	logType, err := normalizeLogType(p.LogType)
+	if err != nil {
+		c.sendError("INVALID_PAYLOAD", err.Error())
+		return
+	}
 
-	topic := liveTailTopic(c.tenantID, p.LogType)
+	topic := liveTailTopic(c.tenantID, logType)
 	if err := c.hub.subscribe(c, topic); err != nil {
 		c.sendError("SUBSCRIBE_FAILED", err.Error())
 		return
@@ -502,13 +669,27 @@ func (c *Client) handleUnsubscribeLiveTail(payload json.RawMessage) {
 		c.sendError("INVALID_PAYLOAD", "log_type is required for unsubscribe_live_tail")
 		return
 	}
+	logType, err := normalizeLogType(p.LogType)
+	if err != nil {
+		c.sendError("INVALID_PAYLOAD", err.Error())
+		return
+	}
 
-	c.hub.unsubscribe(c, liveTailTopic(c.tenantID, p.LogType))
+	c.hub.unsubscribe(c, liveTailTopic(c.tenantID, logType))
+}
+
+// envelope stamps msg with the protocol version of the client.
+func (c *Client) envelope(msg ServerMessage) ServerMessage {
+	msg.Version = 0
+	if c.protocol >= ProtocolV2 {
+		msg.Version = c.protocol
+	}
+	return msg
 }
 
 // sendJSON marshals a ServerMessage and enqueues it for writing.
 func (c *Client) sendJSON(msg ServerMessage) {
-	data, err := json.Marshal(msg)
+	data, err := json.Marshal(c.envelope(msg))
 	if err != nil {
 		c.logger.Error("marshal server message", "error", err)
 		return
@@ -536,17 +717,48 @@ func (c *Client) sendError(code, message string) {
 // Topic naming helpers
 // ---------------------------------------------------------------------------
 
+// Topics are '.'-separated. Every caller-supplied value goes through
+// topicToken, which escapes '.' (and '%', the escape character itself), so
+// a value can never span segments and distinct values always yield distinct
+// topics: no job ID or log type can name another tenant's topic.
+
+// topicToken escapes s for use as a single topic segment.
+func topicToken(s string) string {
+	if !strings.ContainsAny(s, ".%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '.':
+			b.WriteString("%2E")
+		case '%':
+			b.WriteString("%25")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
 // jobProgressTopic returns the internal hub topic for job progress updates.
 func jobProgressTopic(tenantID, jobID string) string {
-	return fmt.Sprintf("job_progress.%s.%s", tenantID, jobID)
+	return fmt.Sprintf("job_progress.%s.%s", topicToken(tenantID), topicToken(jobID))
 }
 
 // jobCompleteTopic returns the internal hub topic for job completion.
 func jobCompleteTopic(tenantID, jobID string) string {
-	return fmt.Sprintf("job_complete.%s.%s", tenantID, jobID)
+	return fmt.Sprintf("job_complete.%s.%s", topicToken(tenantID), topicToken(jobID))
+}
+
+// investigationsTopic returns the internal hub topic for investigation
+// updates of every analysis of a tenant.
+func investigationsTopic(tenantID string) string {
+	return fmt.Sprintf("investigations.%s", topicToken(tenantID))
 }
 
 // liveTailTopic returns the internal hub topic for live tail entries.
 func liveTailTopic(tenantID, logType string) string {
-	return fmt.Sprintf("live_tail.%s.%s", tenantID, logType)
+	return fmt.Sprintf("live_tail.%s.%s", topicToken(tenantID), topicToken(logType))
 }
@@ -12,6 +12,9 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 )
 
 // ---------------------------------------------------------------------------
@@ -132,6 +135,51 @@ func startTestHub(t *testing.T) *Hub {
 	return hub
 }
 
+// startFakeClockHub is startTestHub with a fake clock timing the clients'
+// rate limits and pings.
+func startFakeClockHub(t *testing.T) (*Hub, *clock.Fake) {
+	t.Helper()
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	hub := NewHub()
+	hub.SetClock(fake)
+	go hub.Run()
+	return hub, fake
+}
+
+// settleTopic is the topic settle broadcasts on.
+const settleTopic = "settle"
+
+// settle waits until the hub's event loop has handled every registration
+// and broadcast sent to it before. The loop handles one event at a time and
+// broadcasts in order, so once a broadcast sent now reaches a probe client,
+// all of them are done.
+func settle(t *testing.T, hub *Hub) {
+	t.Helper()
+	probe := newTestClient(hub, settleTopic)
+	require.NoError(t, hub.subscribe(probe, settleTopic))
+	defer hub.unsubscribe(probe, settleTopic)
+	hub.Broadcast(settleTopic, ServerMessage{Type: MsgTypePong})
+	select {
+	case <-probe.send:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub did not settle within 5s")
+	}
+}
+
+// awaitClient waits until the client served on conn has handled every
+// message sent to it before and is registered with the hub: it answers a
+// ping only once it did, and registered before it started reading.
+func awaitClient(t *testing.T, hub *Hub, conn *websocket.Conn) {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: MsgTypePing}))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var resp ServerMessage
+	require.NoError(t, conn.ReadJSON(&resp))
+	require.Equal(t, MsgTypePong, resp.Type)
+	require.NoError(t, conn.SetReadDeadline(time.Time{}))
+	settle(t, hub)
+}
+
 // newTestClient creates a Client with the given hub and tenant, using a
 // buffered send channel but no real WebSocket connection. Useful for testing
 // hub registration, subscription, and broadcast logic.
@@ -154,7 +202,7 @@ func TestHubRegisterAndUnregister(t *testing.T) {
 	hub.register <- client
 
 	// Allow the event loop to process.
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	hub.mu.RLock()
 	_, exists := hub.clients["tenant-A"][client]
@@ -163,7 +211,7 @@ func TestHubRegisterAndUnregister(t *testing.T) {
 
 	// Unregister.
 	hub.unregister <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	hub.mu.RLock()
 	tenantClients, tenantExists := hub.clients["tenant-A"]
@@ -185,7 +233,7 @@ func TestHubRegisterMultipleClients(t *testing.T) {
 	hub.register <- c2
 	hub.register <- c3
 
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	hub.mu.RLock()
 	assert.Len(t, hub.clients["tenant-A"], 2, "tenant-A should have 2 clients")
@@ -198,10 +246,10 @@ func TestHubUnregisterRemovesTenantMapWhenEmpty(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-X")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	hub.unregister <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	hub.mu.RLock()
 	_, exists := hub.clients["tenant-X"]
@@ -214,7 +262,7 @@ func TestHubUnregisterCleansUpTopicSubscriptions(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-A")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Subscribe to some topics.
 	require.NoError(t, hub.subscribe(client, "topic-1"))
@@ -228,7 +276,7 @@ func TestHubUnregisterCleansUpTopicSubscriptions(t *testing.T) {
 
 	// Unregister should clean up all topic subscriptions.
 	hub.unregister <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	hub.mu.RLock()
 	_, t1Exists := hub.topics["topic-1"]
@@ -247,7 +295,7 @@ func TestHubSubscribe(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	err := hub.subscribe(client, "job_progress.tenant-1.job-1")
 	require.NoError(t, err)
@@ -270,7 +318,7 @@ func TestHubSubscribeDuplicate(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	require.NoError(t, hub.subscribe(client, "topic-A"))
 	require.NoError(t, hub.subscribe(client, "topic-A")) // duplicate
@@ -287,7 +335,7 @@ func TestHubSubscribeMaxSubscriptions(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Fill up to the maximum.
 	for i := 0; i < maxSubscriptions; i++ {
@@ -310,7 +358,7 @@ func TestHubUnsubscribe(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	require.NoError(t, hub.subscribe(client, "topic-X"))
 
@@ -332,7 +380,7 @@ func TestHubUnsubscribeNonExistent(t *testing.T) {
 
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Should not panic.
 	hub.unsubscribe(client, "never-subscribed")
@@ -345,7 +393,7 @@ func TestHubUnsubscribePreservesOtherSubscribers(t *testing.T) {
 	c2 := newTestClient(hub, "tenant-1")
 	hub.register <- c1
 	hub.register <- c2
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	require.NoError(t, hub.subscribe(c1, "shared-topic"))
 	require.NoError(t, hub.subscribe(c2, "shared-topic"))
@@ -373,7 +421,7 @@ func TestHubBroadcastToTopic(t *testing.T) {
 	hub.register <- c1
 	hub.register <- c2
 	hub.register <- c3
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	topic := "job_progress.tenant-1.job-42"
 	require.NoError(t, hub.subscribe(c1, topic))
@@ -384,7 +432,7 @@ func TestHubBroadcastToTopic(t *testing.T) {
 	hub.Broadcast(topic, msg)
 
 	// Wait for the broadcast to be processed.
-	time.Sleep(100 * time.Millisecond)
+	settle(t, hub)
 
 	// c1 and c2 should each have received a message.
 	assert.Equal(t, 1, len(c1.send), "c1 should have 1 message")
@@ -398,6 +446,55 @@ func TestHubBroadcastToTopic(t *testing.T) {
 	assert.Equal(t, MsgTypeJobProgress, received.Type)
 }
 
+func TestHubBroadcastStampsProtocolVersion(t *testing.T) {
+	hub := startTestHub(t)
+
+	legacy := newTestClient(hub, "tenant-1")
+	legacy.protocol = ProtocolV1
+	current := newTestClient(hub, "tenant-1")
+	current.protocol = ProtocolV2
+	hub.register <- legacy
+	hub.register <- current
+	settle(t, hub)
+
+	topic := "job_progress.tenant-1.job-42"
+	require.NoError(t, hub.subscribe(legacy, topic))
+	require.NoError(t, hub.subscribe(current, topic))
+	hub.Broadcast(topic, ServerMessage{Type: MsgTypeJobProgress, Payload: map[string]int{"progress_pct": 50}})
+	settle(t, hub)
+
+	require.Len(t, legacy.send, 1)
+	require.Len(t, current.send, 1)
+	assert.JSONEq(t, `{"type":"job_progress","payload":{"progress_pct":50}}`, string(<-legacy.send))
+	assert.JSONEq(t, `{"type":"job_progress","version":2,"payload":{"progress_pct":50}}`, string(<-current.send))
+}
+
+func TestHubNegotiateProtocol(t *testing.T) {
+	hub := NewHub()
+	tests := []struct {
+		offered string
+		want    int
+		wantErr bool
+	}{
+		{"", ProtocolV1, false},
+		{"1", ProtocolV1, false},
+		{"2", ProtocolV2, false},
+		{"1, 2", ProtocolV2, false},
+		{"2,9", ProtocolV2, false},
+		{"9", 0, true},
+		{"latest", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := hub.NegotiateProtocol(tt.offered)
+		if tt.wantErr {
+			assert.Error(t, err, tt.offered)
+			continue
+		}
+		require.NoError(t, err, tt.offered)
+		assert.Equal(t, tt.want, got, tt.offered)
+	}
+}
+
 func TestHubBroadcastToEmptyTopic(t *testing.T) {
 	hub := startTestHub(t)
 
@@ -406,7 +503,7 @@ func TestHubBroadcastToEmptyTopic(t *testing.T) {
 	hub.Broadcast("nonexistent-topic", msg)
 
 	// Allow the hub event loop to process.
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 }
 
 func TestHubBroadcastBackpressure(t *testing.T) {
@@ -421,7 +518,7 @@ func TestHubBroadcastBackpressure(t *testing.T) {
 		logger:        hub.logger.With("tenant", "tenant-bp"),
 	}
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	topic := "bp-topic"
 	require.NoError(t, hub.subscribe(client, topic))
@@ -434,13 +531,45 @@ func TestHubBroadcastBackpressure(t *testing.T) {
 	msg := ServerMessage{Type: "new_msg", Payload: "data"}
 	hub.Broadcast(topic, msg)
 
-	time.Sleep(100 * time.Millisecond)
+	settle(t, hub)
 
 	// The channel should still have 2 items (old one dropped, new one added,
 	// or the new one was dropped -- either way, no panic and no block).
 	assert.LessOrEqual(t, len(client.send), 2, "channel should not exceed capacity")
 }
 
+func TestHubBroadcastBackpressure_KeepsTerminalProgress(t *testing.T) {
+	hub := startTestHub(t)
+
+	client := &Client{
+		hub:           hub,
+		tenantID:      "tenant-bp",
+		send:          make(chan []byte, 2),
+		subscriptions: make(map[string]struct{}),
+		logger:        hub.logger.With("tenant", "tenant-bp"),
+	}
+	hub.register <- client
+	settle(t, hub)
+
+	topic := jobProgressTopic("tenant-bp", "job-1")
+	require.NoError(t, hub.subscribe(client, topic))
+
+	client.send <- []byte(`{"type":"job_progress","payload":{"progress_pct":40}}`)
+	client.send <- []byte(`{"type":"job_progress","payload":{"progress_pct":60}}`)
+
+	hub.Broadcast(topic, ServerMessage{Type: MsgTypeJobProgress, Payload: JobProgress{JobID: "job-1", Status: "complete", ProgressPct: 100}})
+	settle(t, hub)
+
+	require.Len(t, client.send, 2)
+	<-client.send
+	var last ServerMessage
+	require.NoError(t, json.Unmarshal(<-client.send, &last))
+	payload, ok := last.Payload.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "complete", payload["status"])
+	assert.EqualValues(t, 100, payload["progress_pct"])
+}
+
 // ---------------------------------------------------------------------------
 // Concurrent access safety tests
 // ---------------------------------------------------------------------------
@@ -461,7 +590,7 @@ func TestHubConcurrentRegistration(t *testing.T) {
 	}
 
 	wg.Wait()
-	time.Sleep(100 * time.Millisecond)
+	settle(t, hub)
 
 	hub.mu.RLock()
 	count := len(hub.clients["concurrent-tenant"])
@@ -478,7 +607,7 @@ func TestHubConcurrentSubscribeAndBroadcast(t *testing.T) {
 		clients[i] = newTestClient(hub, "concurrent-sub")
 		hub.register <- clients[i]
 	}
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	topic := "concurrent-topic"
 	var wg sync.WaitGroup
@@ -503,7 +632,7 @@ func TestHubConcurrentSubscribeAndBroadcast(t *testing.T) {
 	}
 	wg.Wait()
 
-	time.Sleep(200 * time.Millisecond)
+	settle(t, hub)
 
 	// Every client should have received at least some messages.
 	for i, c := range clients {
@@ -522,7 +651,7 @@ func TestHubConcurrentRegisterUnregister(t *testing.T) {
 		clients[i] = newTestClient(hub, "churn-tenant")
 		hub.register <- clients[i]
 	}
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Unregister half, register new ones concurrently.
 	for i := 0; i < numClients/2; i++ {
@@ -542,7 +671,7 @@ func TestHubConcurrentRegisterUnregister(t *testing.T) {
 	}
 
 	wg.Wait()
-	time.Sleep(100 * time.Millisecond)
+	settle(t, hub)
 
 	// We should not have panicked and the map should be consistent.
 	hub.mu.RLock()
@@ -559,7 +688,7 @@ func TestClientHandleMessagePing(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	raw, err := json.Marshal(ClientMessage{Type: MsgTypePing})
 	require.NoError(t, err)
@@ -578,7 +707,7 @@ func TestClientHandleMessageInvalidJSON(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	client.handleMessage([]byte(`{invalid json`))
 
@@ -594,7 +723,7 @@ func TestClientHandleMessageUnknownType(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	raw, err := json.Marshal(ClientMessage{Type: "totally_unknown"})
 	require.NoError(t, err)
@@ -613,9 +742,9 @@ func TestClientHandleSubscribeJobProgress(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
-	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "job-abc"})
+	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "11111111-1111-1111-1111-111111111111"})
 	raw, _ := json.Marshal(ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
 		Payload: payload,
@@ -624,8 +753,8 @@ func TestClientHandleSubscribeJobProgress(t *testing.T) {
 	client.handleMessage(raw)
 
 	// Should be subscribed to both progress and complete topics.
-	expectedProgress := jobProgressTopic("tenant-1", "job-abc")
-	expectedComplete := jobCompleteTopic("tenant-1", "job-abc")
+	expectedProgress := jobProgressTopic("tenant-1", "11111111-1111-1111-1111-111111111111")
+	expectedComplete := jobCompleteTopic("tenant-1", "11111111-1111-1111-1111-111111111111")
 
 	client.subsMu.Lock()
 	_, hasProgress := client.subscriptions[expectedProgress]
@@ -640,7 +769,7 @@ func TestClientHandleSubscribeJobProgressEmptyJobID(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: ""})
 	raw, _ := json.Marshal(ClientMessage{
@@ -662,7 +791,7 @@ func TestClientHandleSubscribeJobProgressInvalidPayload(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	raw, _ := json.Marshal(ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
@@ -683,10 +812,10 @@ func TestClientHandleUnsubscribeJobProgress(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// First subscribe.
-	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "job-xyz"})
+	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "22222222-2222-2222-2222-222222222222"})
 	subRaw, _ := json.Marshal(ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
 		Payload: payload,
@@ -700,8 +829,8 @@ func TestClientHandleUnsubscribeJobProgress(t *testing.T) {
 	})
 	client.handleMessage(unsubRaw)
 
-	expectedProgress := jobProgressTopic("tenant-1", "job-xyz")
-	expectedComplete := jobCompleteTopic("tenant-1", "job-xyz")
+	expectedProgress := jobProgressTopic("tenant-1", "22222222-2222-2222-2222-222222222222")
+	expectedComplete := jobCompleteTopic("tenant-1", "22222222-2222-2222-2222-222222222222")
 
 	client.subsMu.Lock()
 	_, hasProgress := client.subscriptions[expectedProgress]
@@ -716,7 +845,7 @@ func TestClientHandleUnsubscribeJobProgressEmptyJobID(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: ""})
 	raw, _ := json.Marshal(ClientMessage{
@@ -738,7 +867,7 @@ func TestClientHandleSubscribeLiveTail(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	payload, _ := json.Marshal(SubscribeLiveTailPayload{LogType: "sql"})
 	raw, _ := json.Marshal(ClientMessage{
@@ -761,7 +890,7 @@ func TestClientHandleSubscribeLiveTailEmptyLogType(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	payload, _ := json.Marshal(SubscribeLiveTailPayload{LogType: ""})
 	raw, _ := json.Marshal(ClientMessage{
@@ -783,7 +912,7 @@ func TestClientHandleUnsubscribeLiveTail(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Subscribe first.
 	payload, _ := json.Marshal(SubscribeLiveTailPayload{LogType: "sql"})
@@ -813,7 +942,7 @@ func TestClientHandleUnsubscribeLiveTailEmptyLogType(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	payload, _ := json.Marshal(SubscribeLiveTailPayload{LogType: ""})
 	raw, _ := json.Marshal(ClientMessage{
@@ -835,7 +964,7 @@ func TestClientHandleSubscribeMaxLimitError(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Fill up subscriptions to the max.
 	for i := 0; i < maxSubscriptions; i++ {
@@ -843,7 +972,7 @@ func TestClientHandleSubscribeMaxLimitError(t *testing.T) {
 	}
 
 	// Now try to subscribe via a message -- should get an error back.
-	payload, _ := json.Marshal(SubscribeLiveTailPayload{LogType: "overflow"})
+	payload, _ := json.Marshal(SubscribeLiveTailPayload{LogType: "api"})
 	raw, _ := json.Marshal(ClientMessage{
 		Type:    MsgTypeSubscribeLiveTail,
 		Payload: payload,
@@ -1031,12 +1160,16 @@ func TestMessageTypeConstants(t *testing.T) {
 	assert.Equal(t, "unsubscribe_job_progress", MsgTypeUnsubscribeJobProgress)
 	assert.Equal(t, "subscribe_live_tail", MsgTypeSubscribeLiveTail)
 	assert.Equal(t, "unsubscribe_live_tail", MsgTypeUnsubscribeLiveTail)
+	assert.Equal(t, "subscribe_dashboard", MsgTypeSubscribeDashboard)
+	assert.Equal(t, "unsubscribe_dashboard", MsgTypeUnsubscribeDashboard)
 	assert.Equal(t, "ping", MsgTypePing)
 
 	// Server-to-client.
 	assert.Equal(t, "job_progress", MsgTypeJobProgress)
 	assert.Equal(t, "job_complete", MsgTypeJobComplete)
 	assert.Equal(t, "live_tail_entry", MsgTypeLiveTailEntry)
+	assert.Equal(t, "dashboard_section", MsgTypeDashboardSection)
+	assert.Equal(t, "dashboard_complete", MsgTypeDashboardComplete)
 	assert.Equal(t, "error", MsgTypeError)
 	assert.Equal(t, "pong", MsgTypePong)
 }
@@ -1081,9 +1214,6 @@ func TestWebSocketUpgradeAndPing(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	// Wait for registration.
-	time.Sleep(100 * time.Millisecond)
-
 	// Send a ping message.
 	pingMsg := ClientMessage{Type: MsgTypePing}
 	require.NoError(t, conn.WriteJSON(pingMsg))
@@ -1103,24 +1233,24 @@ func TestWebSocketSubscribeAndReceiveBroadcast(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	// Subscribe to job progress.
-	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "real-job-1"})
+	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "33333333-3333-3333-3333-333333333333"})
 	subMsg := ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
 		Payload: payload,
 	}
 	require.NoError(t, conn.WriteJSON(subMsg))
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	// Broadcast a message from the hub.
-	topic := jobProgressTopic("ws-tenant", "real-job-1")
+	topic := jobProgressTopic("ws-tenant", "33333333-3333-3333-3333-333333333333")
 	hub.Broadcast(topic, ServerMessage{
 		Type: MsgTypeJobProgress,
 		Payload: JobProgress{
-			JobID:       "real-job-1",
+			JobID:       "33333333-3333-3333-3333-333333333333",
 			ProgressPct: 42,
 			Status:      "parsing",
 		},
@@ -1142,7 +1272,7 @@ func TestWebSocketUnknownMessageType(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	// Send an unknown message type.
 	require.NoError(t, conn.WriteJSON(ClientMessage{Type: "bogus"}))
@@ -1163,7 +1293,7 @@ func TestWebSocketInvalidJSON(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	// Send raw invalid JSON bytes.
 	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{not valid`)))
@@ -1188,10 +1318,11 @@ func TestWebSocketMultipleClients(t *testing.T) {
 	require.NoError(t, err)
 	defer conn2.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn1)
+	awaitClient(t, hub, conn2)
 
 	// Both subscribe to the same job topic (they share tenant "ws-tenant").
-	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "shared-job"})
+	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "44444444-4444-4444-4444-444444444444"})
 	subMsg := ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
 		Payload: payload,
@@ -1199,13 +1330,14 @@ func TestWebSocketMultipleClients(t *testing.T) {
 	require.NoError(t, conn1.WriteJSON(subMsg))
 	require.NoError(t, conn2.WriteJSON(subMsg))
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn1)
+	awaitClient(t, hub, conn2)
 
 	// Broadcast.
-	topic := jobProgressTopic("ws-tenant", "shared-job")
+	topic := jobProgressTopic("ws-tenant", "44444444-4444-4444-4444-444444444444")
 	hub.Broadcast(topic, ServerMessage{
 		Type:    MsgTypeJobProgress,
-		Payload: JobProgress{JobID: "shared-job", ProgressPct: 99},
+		Payload: JobProgress{JobID: "44444444-4444-4444-4444-444444444444", ProgressPct: 99},
 	})
 
 	// Both clients should receive the message.
@@ -1227,7 +1359,7 @@ func TestWebSocketCloseGraceful(t *testing.T) {
 	conn, _, err := dialer.Dial(wsURL, nil)
 	require.NoError(t, err)
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	hub.mu.RLock()
 	countBefore := len(hub.clients["ws-tenant"])
@@ -1239,13 +1371,12 @@ func TestWebSocketCloseGraceful(t *testing.T) {
 		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 	conn.Close()
 
-	// Give the hub time to process the unregister.
-	time.Sleep(200 * time.Millisecond)
-
-	hub.mu.RLock()
-	countAfter := len(hub.clients["ws-tenant"])
-	hub.mu.RUnlock()
-	assert.Equal(t, 0, countAfter, "client should be unregistered after close")
+	// ReadPump unregisters the client once it reads the close.
+	require.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return len(hub.clients["ws-tenant"]) == 0
+	}, 2*time.Second, 10*time.Millisecond, "client should be unregistered after close")
 }
 
 // ---------------------------------------------------------------------------
@@ -1261,13 +1392,13 @@ func TestTotalClients(t *testing.T) {
 	hub.register <- c1
 	hub.register <- c2
 	hub.register <- c3
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	total := hub.totalClients()
 	assert.Equal(t, 3, total)
 
 	hub.unregister <- c1
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	total = hub.totalClients()
 	assert.Equal(t, 2, total)
@@ -1305,7 +1436,7 @@ func TestNewClientRegistersWithHub(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	hub.mu.RLock()
 	count := len(hub.clients["reg-tenant"])
@@ -1326,23 +1457,23 @@ func TestWebSocketWritePumpDrainsQueue(t *testing.T) {
 	require.NoError(t, err)
 	defer conn.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	// Subscribe to a topic.
-	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "drain-job"})
+	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "55555555-5555-5555-5555-555555555555"})
 	require.NoError(t, conn.WriteJSON(ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
 		Payload: payload,
 	}))
 
-	time.Sleep(100 * time.Millisecond)
+	awaitClient(t, hub, conn)
 
 	// Broadcast multiple messages rapidly so the WritePump has to drain queued items.
-	topic := jobProgressTopic("ws-tenant", "drain-job")
+	topic := jobProgressTopic("ws-tenant", "55555555-5555-5555-5555-555555555555")
 	for i := 0; i < 5; i++ {
 		hub.Broadcast(topic, ServerMessage{
 			Type:    MsgTypeJobProgress,
-			Payload: JobProgress{JobID: "drain-job", ProgressPct: i * 20},
+			Payload: JobProgress{JobID: "55555555-5555-5555-5555-555555555555", ProgressPct: i * 20},
 		})
 	}
 
@@ -1369,7 +1500,7 @@ func TestClientHandleSubscribeJobProgressCompleteSubFails(t *testing.T) {
 	hub := startTestHub(t)
 	client := newTestClient(hub, "tenant-1")
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// Fill up subscriptions to maxSubscriptions - 1, so the progress
 	// subscription succeeds but the complete subscription fails.
@@ -1379,7 +1510,7 @@ func TestClientHandleSubscribeJobProgressCompleteSubFails(t *testing.T) {
 
 	// Now handleSubscribeJobProgress will subscribe to progress (ok, uses slot 10)
 	// and then try to subscribe to complete (fails, would be slot 11).
-	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "edge-job"})
+	payload, _ := json.Marshal(SubscribeJobProgressPayload{JobID: "66666666-6666-6666-6666-666666666666"})
 	raw, _ := json.Marshal(ClientMessage{
 		Type:    MsgTypeSubscribeJobProgress,
 		Payload: payload,
@@ -1388,14 +1519,14 @@ func TestClientHandleSubscribeJobProgressCompleteSubFails(t *testing.T) {
 	client.handleMessage(raw)
 
 	// The progress subscription should succeed.
-	expectedProgress := jobProgressTopic("tenant-1", "edge-job")
+	expectedProgress := jobProgressTopic("tenant-1", "66666666-6666-6666-6666-666666666666")
 	client.subsMu.Lock()
 	_, hasProgress := client.subscriptions[expectedProgress]
 	client.subsMu.Unlock()
 	assert.True(t, hasProgress, "progress subscription should succeed")
 
 	// The complete subscription should have failed (non-fatal).
-	expectedComplete := jobCompleteTopic("tenant-1", "edge-job")
+	expectedComplete := jobCompleteTopic("tenant-1", "66666666-6666-6666-6666-666666666666")
 	client.subsMu.Lock()
 	_, hasComplete := client.subscriptions[expectedComplete]
 	client.subsMu.Unlock()
@@ -1421,7 +1552,7 @@ func TestHubBroadcastDropsWhenClientTooSlow(t *testing.T) {
 		logger:        hub.logger.With("tenant", "tenant-slow"),
 	}
 	hub.register <- client
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	topic := "slow-topic"
 	require.NoError(t, hub.subscribe(client, topic))
@@ -1431,7 +1562,7 @@ func TestHubBroadcastDropsWhenClientTooSlow(t *testing.T) {
 
 	// First broadcast: will drain the old message and insert new one.
 	hub.Broadcast(topic, ServerMessage{Type: "msg1"})
-	time.Sleep(50 * time.Millisecond)
+	settle(t, hub)
 
 	// The channel should have exactly 1 message (the new one replaced the old).
 	assert.Equal(t, 1, len(client.send))
@@ -1444,8 +1575,115 @@ func TestHubBroadcastDropsWhenClientTooSlow(t *testing.T) {
 	// The first broadcast will try to drain and reinsert, the second may drop.
 	hub.Broadcast(topic, ServerMessage{Type: "rapid1"})
 	hub.Broadcast(topic, ServerMessage{Type: "rapid2"})
-	time.Sleep(100 * time.Millisecond)
+	settle(t, hub)
 
 	// Should not panic and channel should not exceed capacity.
 	assert.LessOrEqual(t, len(client.send), 1)
 }
+
+func TestClientHandleSubscribeInvestigations(t *testing.T) {
+	hub := startTestHub(t)
+	client := newTestClient(hub, "tenant-1")
+	other := newTestClient(hub, "tenant-2")
+	hub.register <- client
+	hub.register <- other
+	settle(t, hub)
+
+	raw, _ := json.Marshal(ClientMessage{Type: MsgTypeSubscribeInvestigations})
+	client.handleMessage(raw)
+	other.handleMessage(raw)
+
+	hub.BroadcastInvestigation("tenant-1", domain.InvestigationEvent{
+		FromStatus: domain.InvestigationNew,
+		ToStatus:   domain.InvestigationInvestigating,
+		Version:    1,
+	})
+	settle(t, hub)
+
+	require.Equal(t, 1, len(client.send), "subscribed tenant should receive the update")
+	assert.Equal(t, 0, len(other.send), "other tenants must not receive the update")
+
+	var received ServerMessage
+	require.NoError(t, json.Unmarshal(<-client.send, &received))
+	assert.Equal(t, MsgTypeInvestigation, received.Type)
+
+	unsub, _ := json.Marshal(ClientMessage{Type: MsgTypeUnsubscribeInvestigations})
+	client.handleMessage(unsub)
+	client.subsMu.Lock()
+	_, subscribed := client.subscriptions[investigationsTopic("tenant-1")]
+	client.subsMu.Unlock()
+	assert.False(t, subscribed)
+}
+
+// ---------------------------------------------------------------------------
+// Timing of the pumps, on a fake clock
+// ---------------------------------------------------------------------------
+
+func TestWebSocketWritePumpPingsEveryPingPeriod(t *testing.T) {
+	hub, fake := startFakeClockHub(t)
+	_, wsURL := wsTestServer(t, hub)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	pings := make(chan struct{}, 2)
+	conn.SetPingHandler(func(string) error {
+		pings <- struct{}{}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The write pump's ticker is the only timer of the clock.
+	fake.BlockUntil(1)
+	for i := 1; i <= 2; i++ {
+		fake.Advance(pingPeriod)
+		select {
+		case <-pings:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no ping %d after the ping period", i)
+		}
+	}
+}
+
+func TestWebSocketReadPumpRateLimit(t *testing.T) {
+	hub, fake := startFakeClockHub(t)
+	_, wsURL := wsTestServer(t, hub)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	read := func() (string, ErrorPayload) {
+		t.Helper()
+		var msg struct {
+			Type    string       `json:"type"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, conn.ReadJSON(&msg))
+		return msg.Type, msg.Payload
+	}
+
+	// The clock stands still, so the burst is all the client may send.
+	for i := 0; i <= clientMessageBurst; i++ {
+		require.NoError(t, conn.WriteJSON(ClientMessage{Type: MsgTypePing}))
+	}
+	for i := 0; i < clientMessageBurst; i++ {
+		typ, _ := read()
+		require.Equal(t, MsgTypePong, typ, "message %d within burst", i)
+	}
+	typ, payload := read()
+	assert.Equal(t, MsgTypeError, typ)
+	assert.Equal(t, "RATE_LIMITED", payload.Code)
+
+	// One message's worth of tokens later the client may send again.
+	fake.Advance(time.Second / clientMessageRate)
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: MsgTypePing}))
+	typ, _ = read()
+	assert.Equal(t, MsgTypePong, typ)
+}
@@ -0,0 +1,317 @@
+// Package tabular turns the responses of the API into tables: every slice
+// of structs a response holds becomes a table whose columns are the fields
+// of the struct, named and ordered as they are in JSON. The tables are
+// written as CSV or as a one-sheet XLSX workbook.
+package tabular
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDepth bounds how deep structs are flattened into columns and searched
+// for tables, so that recursive types end.
+const maxDepth = 4
+
+// Table is one slice of structs found in a value.
+type Table struct {
+	// Name is the dotted JSON path of the slice within the value, empty
+	// when the value is the slice.
+	Name string
+	// Headers names the columns: the JSON name of each field, dotted for
+	// the fields of nested structs. A `table:"..."` tag renames a field and
+	// `table:"-"` leaves it out.
+	Headers []string
+	// Rows holds one cell per column: nil for a missing value, or a string,
+	// int64, uint64, float64, bool or time.Time.
+	Rows [][]any
+}
+
+// Tables returns the tables of v in field order. Tables behind nil
+// pointers are returned with no rows, so that their columns are known.
+func Tables(v any) []Table {
+	var tables []Table
+	collect(reflect.TypeOf(v), reflect.ValueOf(v), "", 0, &tables)
+	return tables
+}
+
+// Lookup returns the table of v named name, or when name is empty the
+// first table with rows, else the first table.
+func Lookup(v any, name string) (Table, bool) {
+	tables := Tables(v)
+	if name != "" {
+		for _, t := range tables {
+			if t.Name == name {
+				return t, true
+			}
+		}
+		return Table{}, false
+	}
+	for _, t := range tables {
+		if len(t.Rows) > 0 {
+			return t, true
+		}
+	}
+	if len(tables) > 0 {
+		return tables[0], true
+	}
+	return Table{}, false
+}
+
+// Names returns the names of the tables of v.
+func Names(v any) []string {
+	var names []string
+	for _, t := range Tables(v) {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// collect appends the tables of v, of type t, found at path. v may be
+// invalid when it sits behind a nil pointer.
+func collect(t reflect.Type, v reflect.Value, path string, depth int, tables *[]Table) {
+	if t == nil || depth > maxDepth {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if v.IsValid() {
+			v = v.Elem()
+		}
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := indirect(t.Elem())
+		if elem.Kind() != reflect.Struct || leaf(elem) {
+			return
+		}
+		table := Table{Name: path}
+		cols := columns(elem, nil, "", 0)
+		for _, c := range cols {
+			table.Headers = append(table.Headers, c.header)
+		}
+		if v.IsValid() {
+			for i := 0; i < v.Len(); i++ {
+				table.Rows = append(table.Rows, row(v.Index(i), cols))
+			}
+		}
+		*tables = append(*tables, table)
+	case reflect.Struct:
+		if leaf(t) {
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := fieldName(f)
+			if !ok {
+				continue
+			}
+			var fv reflect.Value
+			if v.IsValid() {
+				fv = v.Field(i)
+			}
+			collect(f.Type, fv, join(path, name), depth+1, tables)
+		}
+	}
+}
+
+// column is one column of a table: the field reached from a row through
+// index, one field index per struct.
+type column struct {
+	header string
+	index  []int
+}
+
+// columns returns the columns of struct t, reached from a row through
+// prefix and named under path.
+func columns(t reflect.Type, prefix []int, path string, depth int) []column {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		index := append(append([]int(nil), prefix...), i)
+		ft := indirect(f.Type)
+		switch {
+		case ft.Kind() == reflect.Struct && !leaf(ft):
+			if depth >= maxDepth {
+				continue
+			}
+			sub := path
+			if name != "" {
+				sub = join(path, name)
+			}
+			cols = append(cols, columns(ft, index, sub, depth+1)...)
+		case nested(ft):
+			// Slices of structs are tables of their own.
+		default:
+			cols = append(cols, column{header: join(path, name), index: index})
+		}
+	}
+	return cols
+}
+
+// fieldName returns the name of field f within its table path, empty for
+// an embedded struct whose fields are promoted, and whether f is tabulated
+// at all.
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() && !(f.Anonymous && indirect(f.Type).Kind() == reflect.Struct) {
+		return "", false
+	}
+	tag := f.Tag.Get("table")
+	if tag == "-" {
+		return "", false
+	}
+	jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if jsonName == "-" {
+		return "", false
+	}
+	switch {
+	case tag != "":
+		return tag, true
+	case jsonName != "":
+		return jsonName, true
+	case f.Anonymous:
+		return "", true
+	default:
+		return f.Name, true
+	}
+}
+
+// nested reports whether t holds structs that do not fit in a cell.
+func nested(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		elem := indirect(t.Elem())
+		return t.Elem().Kind() != reflect.Uint8 && (elem.Kind() == reflect.Struct && !leaf(elem) || nested(elem))
+	}
+	return false
+}
+
+type timeLike interface{ UTC() time.Time }
+
+var (
+	timeLikeType      = reflect.TypeFor[timeLike]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	stringerType      = reflect.TypeFor[fmt.Stringer]()
+)
+
+// leaf reports whether values of t fill a single cell although t is a
+// struct or an array: times and values that render themselves as text.
+func leaf(t reflect.Type) bool {
+	return t.Implements(timeLikeType) || t.Implements(textMarshalerType) || t.Implements(stringerType)
+}
+
+func row(v reflect.Value, cols []column) []any {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	cells := make([]any, len(cols))
+	for i, c := range cols {
+		cells[i] = cell(field(v, c.index))
+	}
+	return cells
+}
+
+// field follows index from v, returning an invalid value at a nil pointer.
+func field(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// cell converts v to the value of a cell.
+func cell(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	switch {
+	case t.Implements(timeLikeType):
+		tm := v.Interface().(timeLike).UTC()
+		if tm.IsZero() {
+			return nil
+		}
+		return tm
+	case t.Implements(textMarshalerType):
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	case t.Implements(stringerType):
+		return v.Interface().(fmt.Stringer).String()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return nil
+		}
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = text(cell(v.Index(i)))
+		}
+		return strings.Join(parts, "; ")
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		parts := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			parts = append(parts, text(cell(iter.Key()))+"="+text(cell(iter.Value())))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, "; ")
+	}
+	return nil
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	if name == "" {
+		return path
+	}
+	return path + "." + name
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
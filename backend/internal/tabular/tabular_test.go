@@ -0,0 +1,216 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+type hint struct {
+	Kind   string  `json:"kind"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+type meta struct {
+	Sampled bool `json:"sampled"`
+}
+
+type item struct {
+	Name     string            `json:"name"`
+	Count    int64             `json:"count"`
+	Rate     float64           `json:"rate"`
+	Seen     domain.Timestamp  `json:"seen"`
+	Until    *domain.Timestamp `json:"until,omitempty"`
+	ID       uuid.UUID         `json:"id"`
+	Hint     *hint             `json:"hint,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]int    `json:"labels,omitempty"`
+	Children []hint            `json:"children"`
+	Renamed  int               `json:"renamed" table:"Renamed Column"`
+	Skipped  string            `json:"skipped" table:"-"`
+	Hidden   string            `json:"-"`
+	private  string
+
+	meta
+}
+
+type response struct {
+	Items []item `json:"items"`
+	Group *struct {
+		Rows []hint `json:"rows"`
+	} `json:"group,omitempty"`
+	Total int `json:"total"`
+}
+
+func TestTables_FlattensNestedAndOptionalFields(t *testing.T) {
+	seen := time.Date(2026, 3, 9, 8, 0, 0, 123e6, time.UTC)
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	resp := response{Items: []item{
+		{
+			Name: "GET_ENTRY", Count: 12, Rate: 0.25, Seen: domain.NewTimestamp(seen), ID: id,
+			Hint: &hint{Kind: "restart", Weight: 1.5}, Tags: []string{"a", "b"},
+			Labels: map[string]int{"z": 2, "a": 1}, Children: []hint{{Kind: "x"}}, Renamed: 7,
+			meta: meta{Sampled: true},
+		},
+		{Name: "SET_ENTRY"},
+	}}
+
+	tables := Tables(&resp)
+	require.Len(t, tables, 2)
+	assert.Equal(t, "items", tables[0].Name)
+	assert.Equal(t, "group.rows", tables[1].Name, "tables behind nil pointers are listed")
+	assert.Equal(t, []string{"kind", "weight"}, tables[1].Headers)
+	assert.Empty(t, tables[1].Rows)
+
+	items := tables[0]
+	assert.Equal(t, []string{
+		"name", "count", "rate", "seen", "until", "id", "hint.kind", "hint.weight",
+		"tags", "labels", "Renamed Column", "sampled",
+	}, items.Headers, "nested structs are flattened, slices of structs and skipped fields left out")
+	require.Len(t, items.Rows, 2)
+	assert.Equal(t, []any{
+		"GET_ENTRY", int64(12), 0.25, seen, nil, id.String(), "restart", 1.5,
+		"a; b", "a=1; z=2", int64(7), true,
+	}, items.Rows[0])
+	assert.Equal(t, []any{
+		"SET_ENTRY", int64(0), 0.0, nil, nil, uuid.Nil.String(), nil, nil,
+		nil, nil, int64(0), false,
+	}, items.Rows[1], "nil pointers and zero times are empty cells")
+}
+
+func TestTables_SliceValue(t *testing.T) {
+	tables := Tables([]*hint{{Kind: "a"}, nil})
+	require.Len(t, tables, 1)
+	assert.Equal(t, "", tables[0].Name)
+	assert.Equal(t, [][]any{{"a", 0.0}, {nil, nil}}, tables[0].Rows)
+}
+
+func TestLookup(t *testing.T) {
+	resp := response{Group: &struct {
+		Rows []hint `json:"rows"`
+	}{Rows: []hint{{Kind: "a"}}}}
+
+	table, ok := Lookup(resp, "")
+	require.True(t, ok)
+	assert.Equal(t, "group.rows", table.Name, "the first table with rows by default")
+
+	table, ok = Lookup(resp, "items")
+	require.True(t, ok)
+	assert.Empty(t, table.Rows)
+
+	_, ok = Lookup(resp, "nope")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"items", "group.rows"}, Names(resp))
+
+	_, ok = Lookup(struct{ N int }{}, "")
+	assert.False(t, ok)
+}
+
+func TestWriteCSV(t *testing.T) {
+	seen := time.Date(2026, 3, 9, 8, 0, 0, 123e6, time.UTC)
+	table := Table{
+		Headers: []string{"name", "count", "rate", "seen", "note"},
+		Rows: [][]any{
+			{"a, b", int64(3), 0.5, seen, nil},
+			{"=HYPERLINK()", uint64(4), 2.0, nil, true},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, table))
+
+	assert.Equal(t, "name,count,rate,seen,note\n"+
+		"\"a, b\",3,0.5,2026-03-09T08:00:00.123Z,\n"+
+		"'=HYPERLINK(),4,2,,true\n", buf.String(), "numbers bare, timestamps ISO, formulas defused")
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+}
+
+func TestWriteXLSX(t *testing.T) {
+	table := Table{
+		Name:    "api.groups",
+		Headers: []string{"name", "count", "ok", "note"},
+		Rows: [][]any{
+			{"<GET & SET>", int64(3), true, nil},
+			{strings.Repeat("x", 100), 1.5, false, "n"},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteXLSX(&buf, table))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		assert.Contains(t, parts, name)
+	}
+
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="api.groups"`)
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	assert.Contains(t, sheet, `<col min="1" max="1" width="60" customWidth="1"/>`, "widths are capped")
+	assert.Contains(t, sheet, `<col min="2" max="2" width="8" customWidth="1"/>`)
+	assert.Contains(t, sheet, `<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">name</t></is></c>`)
+	assert.Contains(t, sheet, `&lt;GET &amp; SET&gt;`)
+	assert.Contains(t, sheet, `<c r="B2"><v>3</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2" t="b"><v>1</v></c>`)
+	assert.Contains(t, sheet, `<c r="B3"><v>1.5</v></c>`)
+	assert.NotContains(t, sheet, `r="D2"`, "empty cells are left out")
+}
+
+func TestWriteXLSX_MoreSheets(t *testing.T) {
+	table := Table{Name: "groups", Headers: []string{"name"}, Rows: [][]any{{"a"}}}
+	info := Table{Name: "Provenance", Headers: []string{"field", "value"}, Rows: [][]any{{"parser_version", "2"}}}
+	var buf bytes.Buffer
+	require.NoError(t, WriteXLSX(&buf, table, info))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="groups" sheetId="1" r:id="rId1"/><sheet name="Provenance" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, parts["xl/_rels/workbook.xml.rels"], `Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"`)
+	assert.Contains(t, parts["xl/_rels/workbook.xml.rels"], `Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"`)
+	assert.Contains(t, parts["[Content_Types].xml"], `<Override PartName="/xl/worksheets/sheet2.xml"`)
+	assert.Contains(t, parts["xl/worksheets/sheet1.xml"], `<t xml:space="preserve">a</t>`)
+	assert.Contains(t, parts["xl/worksheets/sheet2.xml"], `<t xml:space="preserve">parser_version</t>`)
+}
+
+func TestColumnAndSheetNames(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+
+	assert.Equal(t, "Sheet1", sheetName(""))
+	assert.Equal(t, "a_b_c", sheetName("a/b:c"))
+	assert.Len(t, sheetName(strings.Repeat("s", 40)), 31)
+}
@@ -0,0 +1,289 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Content types of the formats tables are written in.
+const (
+	ContentTypeCSV  = "text/csv; charset=utf-8"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// timeLayout writes times as the API does: RFC 3339 in UTC with
+// millisecond precision.
+const timeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// text renders a cell as it is written in CSV.
+func text(c any) string {
+	switch c := c.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case int64:
+		return strconv.FormatInt(c, 10)
+	case uint64:
+		return strconv.FormatUint(c, 10)
+	case float64:
+		return strconv.FormatFloat(c, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(c)
+	case time.Time:
+		return c.UTC().Format(timeLayout)
+	}
+	return fmt.Sprint(c)
+}
+
+// safeText keeps a spreadsheet from evaluating a string cell as a formula.
+func safeText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// WriteCSV writes t as CSV with a header row. Numbers are written bare and
+// only strings that need it are quoted.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Headers); err != nil {
+		return err
+	}
+	record := make([]string, len(t.Headers))
+	for _, r := range t.Rows {
+		for i, c := range r {
+			if s, ok := c.(string); ok {
+				record[i] = safeText(s)
+			} else {
+				record[i] = text(c)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Bounds of the column widths of an XLSX sheet, in characters.
+const (
+	minColumnWidth = 8
+	maxColumnWidth = 60
+)
+
+// WriteXLSX writes t as a workbook of one sheet with a bold, frozen header
+// row and columns as wide as their content. Numbers and booleans are
+// written as such, times as ISO 8601 text. Each of more is written to a
+// sheet of its own after t's; sheet names must be distinct.
+func WriteXLSX(w io.Writer, t Table, more ...Table) error {
+	sheets := append([]Table{t}, more...)
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"[Content_Types].xml", func(w io.Writer) error { return writeContentTypes(w, len(sheets)) }},
+		{"_rels/.rels", constant(xlsxRootRels)},
+		{"xl/workbook.xml", func(w io.Writer) error { return writeWorkbook(w, sheets) }},
+		{"xl/_rels/workbook.xml.rels", func(w io.Writer) error { return writeWorkbookRels(w, len(sheets)) }},
+		{"xl/styles.xml", constant(xlsxStyles)},
+	}
+	for i := range sheets {
+		sheet := sheets[i]
+		files = append(files, struct {
+			name  string
+			write func(io.Writer) error
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), func(w io.Writer) error { return writeSheet(w, sheet) }})
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if err := f.write(fw); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func writeContentTypes(w io.Writer, sheets int) error {
+	var b strings.Builder
+	b.WriteString(xlsxContentTypesHead)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeWorkbook(w io.Writer, sheets []Table) error {
+	var b strings.Builder
+	b.WriteString(xlsxWorkbookHead)
+	for i, t := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheetName(t.Name)), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeWorkbookRels relates sheet i to rId{i} and the styles to the id
+// after the last sheet's.
+func writeWorkbookRels(w io.Writer, sheets int) error {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSheet(w io.Writer, t Table) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	bw.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+
+	if len(t.Headers) > 0 {
+		bw.WriteString("<cols>")
+		for i, width := range columnWidths(t) {
+			fmt.Fprintf(bw, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+		}
+		bw.WriteString("</cols>")
+	}
+
+	bw.WriteString("<sheetData>")
+	header := make([]any, len(t.Headers))
+	for i, h := range t.Headers {
+		header[i] = h
+	}
+	writeRow(bw, 1, header, ` s="1"`)
+	for i, r := range t.Rows {
+		writeRow(bw, i+2, r, "")
+	}
+	bw.WriteString("</sheetData></worksheet>")
+	return bw.Flush()
+}
+
+func writeRow(w *bufio.Writer, n int, cells []any, style string) {
+	fmt.Fprintf(w, `<row r="%d">`, n)
+	for i, c := range cells {
+		ref := columnName(i) + strconv.Itoa(n)
+		switch c := c.(type) {
+		case nil:
+		case float64:
+			if math.IsNaN(c) || math.IsInf(c, 0) {
+				fmt.Fprintf(w, `<c r="%s"%s t="inlineStr"><is><t>%s</t></is></c>`, ref, style, text(c))
+				break
+			}
+			fmt.Fprintf(w, `<c r="%s"%s><v>%s</v></c>`, ref, style, text(c))
+		case int64, uint64:
+			fmt.Fprintf(w, `<c r="%s"%s><v>%s</v></c>`, ref, style, text(c))
+		case bool:
+			v := "0"
+			if c {
+				v = "1"
+			}
+			fmt.Fprintf(w, `<c r="%s"%s t="b"><v>%s</v></c>`, ref, style, v)
+		default:
+			fmt.Fprintf(w, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(text(c)))
+		}
+	}
+	w.WriteString("</row>")
+}
+
+func columnWidths(t Table) []int {
+	widths := make([]int, len(t.Headers))
+	for i, h := range t.Headers {
+		widths[i] = utf8.RuneCountInString(h) + 2
+	}
+	for _, r := range t.Rows {
+		for i, c := range r {
+			widths[i] = max(widths[i], utf8.RuneCountInString(text(c))+2)
+		}
+	}
+	for i := range widths {
+		widths[i] = min(max(widths[i], minColumnWidth), maxColumnWidth)
+	}
+	return widths
+}
+
+// columnName returns the letters of the i-th column, from 0: A, ..., Z, AA.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName makes name a valid sheet name: at most 31 characters, none of
+// []:*?/\.
+func sheetName(name string) string {
+	if name == "" {
+		return "Sheet1"
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if utf8.RuneCountInString(name) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func constant(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+const xlsxContentTypesHead = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookHead = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`
+
+// xlsxStyles defines the default cell style and a bold one for the header.
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,336 @@
+// Package tasks runs the worker's periodic background tasks. Every worker
+// replica runs the same tasks; a lock held in Postgres lets only one of them
+// run a task at a time. Runs are recorded with their outcome, and any task
+// can be run outside its schedule on request.
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// DefaultTimeout bounds a run of a task that does not set its own
+	// timeout.
+	DefaultTimeout = 30 * time.Minute
+
+	// DefaultPollInterval is how often the runner looks for due tasks.
+	DefaultPollInterval = 5 * time.Second
+
+	// maxJitter caps the random delay added to a task's next run, so that
+	// replicas and tasks sharing a schedule do not all fire at once.
+	maxJitter = time.Minute
+
+	// lockGrace is how long a lock outlives the timeout of its run, so that
+	// a replica that died mid-run only blocks the task that long.
+	lockGrace = time.Minute
+
+	// finishTimeout bounds recording a run once it is over.
+	finishTimeout = 10 * time.Second
+)
+
+// Task is a periodic background job.
+type Task interface {
+	// Name identifies the task across replicas and restarts.
+	Name() string
+	// Schedule decides when the task runs.
+	Schedule() Schedule
+	// Run does the task's work once. ctx is cancelled at the task's
+	// timeout and at shutdown.
+	Run(ctx context.Context) error
+}
+
+// Timeouter is implemented by the tasks that set their own run timeout.
+type Timeouter interface {
+	Timeout() time.Duration
+}
+
+// New returns the task named name running fn on schedule, each run bounded
+// by timeout, or DefaultTimeout when it is not positive.
+func New(name string, schedule Schedule, timeout time.Duration, fn func(ctx context.Context) error) Task {
+	return &funcTask{name: name, schedule: schedule, timeout: timeout, fn: fn}
+}
+
+type funcTask struct {
+	name     string
+	schedule Schedule
+	timeout  time.Duration
+	fn       func(ctx context.Context) error
+}
+
+func (t *funcTask) Name() string                  { return t.name }
+func (t *funcTask) Schedule() Schedule            { return t.schedule }
+func (t *funcTask) Run(ctx context.Context) error { return t.fn(ctx) }
+func (t *funcTask) Timeout() time.Duration        { return t.timeout }
+
+// timeoutOf returns the run timeout of t.
+func timeoutOf(t Task) time.Duration {
+	if to, ok := t.(Timeouter); ok && to.Timeout() > 0 {
+		return to.Timeout()
+	}
+	return DefaultTimeout
+}
+
+// Store persists the registered tasks, their locks and their run history.
+type Store interface {
+	RegisterBackgroundTask(ctx context.Context, task *domain.BackgroundTask) error
+	DueBackgroundTasks(ctx context.Context, now time.Time) ([]string, error)
+	ClaimBackgroundTask(ctx context.Context, name, workerID string, now, lockedUntil, nextRunAt time.Time) (string, bool, error)
+	FinishBackgroundTaskRun(ctx context.Context, run *domain.BackgroundTaskRun) error
+}
+
+// Runner runs the registered tasks when they are due and this replica
+// claims them.
+type Runner struct {
+	store        Store
+	workerID     string
+	pollInterval time.Duration
+	tasks        map[string]Task
+	order        []string
+
+	// clock times the polls, stamps the runs and times the grace given a
+	// run past its timeout; jitter is replaced in tests. The timeout itself
+	// is a context deadline, on the system clock.
+	clock  clock.Clock
+	jitter func(limit time.Duration) time.Duration
+
+	// active holds the tasks running on this replica. A run abandoned at
+	// its timeout stays active until it returns, so the replica never runs
+	// a task twice at once.
+	mu     sync.Mutex
+	active map[string]bool
+	runs   sync.WaitGroup
+}
+
+// NewRunner creates a Runner claiming tasks as workerID.
+func NewRunner(store Store, workerID string) *Runner {
+	return &Runner{
+		store:        store,
+		workerID:     workerID,
+		pollInterval: DefaultPollInterval,
+		tasks:        make(map[string]Task),
+		clock:        clock.Real,
+		jitter:       randomJitter,
+		active:       make(map[string]bool),
+	}
+}
+
+// SetPollInterval sets how often the runner looks for due tasks. Run-now
+// requests start within about one interval.
+func (r *Runner) SetPollInterval(d time.Duration) {
+	if d > 0 {
+		r.pollInterval = d
+	}
+}
+
+// SetClock replaces the clock the runner polls and stamps runs with.
+func (r *Runner) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// now returns the time of the runner's clock in UTC.
+func (r *Runner) now() time.Time {
+	return r.clock.Now().UTC()
+}
+
+// Register adds a task. Tasks are registered before Run; a second task of
+// the same name replaces the first.
+func (r *Runner) Register(t Task) {
+	if _, ok := r.tasks[t.Name()]; !ok {
+		r.order = append(r.order, t.Name())
+	}
+	r.tasks[t.Name()] = t
+}
+
+// Run records the registered tasks and runs them as they fall due until ctx
+// is cancelled, then waits for the runs in flight, which see ctx cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	for r.register(ctx) != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(r.pollInterval):
+		}
+	}
+	defer r.runs.Wait()
+
+	timer := r.clock.NewTimer(r.pollInterval)
+	defer timer.Stop()
+	for {
+		r.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(r.pollInterval + r.jitter(r.pollInterval/10))
+		}
+	}
+}
+
+// register records every task with its first run.
+func (r *Runner) register(ctx context.Context) error {
+	now := r.now()
+	for _, name := range r.order {
+		t := r.tasks[name]
+		err := r.store.RegisterBackgroundTask(ctx, &domain.BackgroundTask{
+			Name:           name,
+			Schedule:       t.Schedule().String(),
+			TimeoutSeconds: int(timeoutOf(t) / time.Second),
+			NextRunAt:      r.nextRun(t, now),
+		})
+		if err != nil {
+			slog.Warn("failed to register background task", "task", name, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// nextRun returns the jittered run of t following now.
+func (r *Runner) nextRun(t Task, now time.Time) time.Time {
+	next := t.Schedule().Next(now)
+	if next.IsZero() {
+		// A schedule that never fires again is pushed out of reach; the
+		// task still runs on request.
+		return now.AddDate(100, 0, 0)
+	}
+	return next.Add(r.jitter(min(next.Sub(now)/10, maxJitter)))
+}
+
+// poll claims the due tasks not running on this replica and starts them.
+func (r *Runner) poll(ctx context.Context) {
+	due, err := r.store.DueBackgroundTasks(ctx, r.now())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("failed to list due background tasks", "error", err)
+		}
+		return
+	}
+	for _, name := range due {
+		t, ok := r.tasks[name]
+		if !ok || !r.activate(name) {
+			continue
+		}
+		now := r.now()
+		timeout := timeoutOf(t)
+		trigger, claimed, err := r.store.ClaimBackgroundTask(ctx, name, r.workerID, now, now.Add(timeout+lockGrace), r.nextRun(t, now))
+		if err != nil || !claimed {
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("failed to claim background task", "task", name, "error", err)
+			}
+			r.deactivate(name)
+			continue
+		}
+		r.runs.Add(1)
+		go func() {
+			defer r.runs.Done()
+			r.execute(ctx, t, trigger, timeout)
+		}()
+	}
+}
+
+func (r *Runner) activate(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active[name] {
+		return false
+	}
+	r.active[name] = true
+	return true
+}
+
+func (r *Runner) deactivate(name string) {
+	r.mu.Lock()
+	delete(r.active, name)
+	r.mu.Unlock()
+}
+
+// execute runs t once, recovering a panic and abandoning the run at its
+// timeout, then records the run and releases the task.
+func (r *Runner) execute(ctx context.Context, t Task, trigger string, timeout time.Duration) {
+	run := &domain.BackgroundTaskRun{
+		TaskName:  t.Name(),
+		Trigger:   trigger,
+		WorkerID:  r.workerID,
+		StartedAt: r.now(),
+	}
+	logger := slog.With("task", run.TaskName, "trigger", trigger)
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		// The task stays active here until it returns, even when its run
+		// was abandoned.
+		defer r.deactivate(run.TaskName)
+		defer func() {
+			if p := recover(); p != nil {
+				logger.Error("background task panicked", "panic", p, "stack", string(debug.Stack()))
+				done <- &panicError{value: p}
+			}
+		}()
+		done <- t.Run(runCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-runCtx.Done():
+		// Give a task honouring cancellation a moment to return its own
+		// error before abandoning it.
+		select {
+		case err = <-done:
+		case <-r.clock.After(time.Second):
+			err = runCtx.Err()
+		}
+	}
+
+	run.FinishedAt = r.now()
+	run.DurationMS = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Outcome = domain.TaskOutcomeSuccess
+	var pe *panicError
+	switch {
+	case err == nil:
+	case errors.As(err, &pe):
+		run.Outcome, run.Error = domain.TaskOutcomePanic, err.Error()
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		run.Outcome, run.Error = domain.TaskOutcomeTimeout, fmt.Sprintf("timed out after %s: %v", timeout, err)
+	default:
+		run.Outcome, run.Error = domain.TaskOutcomeError, err.Error()
+	}
+	if run.Outcome == domain.TaskOutcomeSuccess {
+		logger.Debug("background task finished", "duration_ms", run.DurationMS)
+	} else {
+		logger.Warn("background task failed", "outcome", run.Outcome, "duration_ms", run.DurationMS, "error", run.Error)
+	}
+
+	finishCtx, finishCancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer finishCancel()
+	if err := r.store.FinishBackgroundTaskRun(finishCtx, run); err != nil {
+		logger.Warn("failed to record background task run", "error", err)
+	}
+}
+
+// panicError is the error of a run that panicked.
+type panicError struct {
+	value any
+}
+
+func (e *panicError) Error() string { return fmt.Sprintf("panic: %v", e.value) }
+
+// randomJitter returns a random duration in [0, limit).
+func randomJitter(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit)
+}
@@ -0,0 +1,344 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// memoryStore is a Store keeping the tasks in memory with the semantics of
+// the Postgres store. Its clock is that of the runners of the test.
+type memoryStore struct {
+	mu    sync.Mutex
+	tasks map[string]*domain.BackgroundTask
+	runs  []domain.BackgroundTaskRun
+	clock *clock.Fake
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		tasks: make(map[string]*domain.BackgroundTask),
+		clock: clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)),
+	}
+}
+
+func (s *memoryStore) RegisterBackgroundTask(_ context.Context, task *domain.BackgroundTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.tasks[task.Name]; ok && prev.Schedule == task.Schedule {
+		prev.TimeoutSeconds = task.TimeoutSeconds
+		return nil
+	}
+	t := *task
+	s.tasks[task.Name] = &t
+	return nil
+}
+
+func (s *memoryStore) DueBackgroundTasks(_ context.Context, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name, t := range s.tasks {
+		if s.due(t, now) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (s *memoryStore) due(t *domain.BackgroundTask, now time.Time) bool {
+	return (!t.NextRunAt.After(now) || t.RunRequestedAt != nil) &&
+		(t.LockedUntil == nil || !t.LockedUntil.After(now))
+}
+
+func (s *memoryStore) ClaimBackgroundTask(_ context.Context, name, workerID string, now, lockedUntil, nextRunAt time.Time) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[name]
+	if !ok || !s.due(t, now) {
+		return "", false, nil
+	}
+	trigger := domain.TaskTriggerSchedule
+	if t.RunRequestedAt != nil {
+		trigger = domain.TaskTriggerManual
+	}
+	t.LockedBy, t.LockedUntil = workerID, &lockedUntil
+	t.RunRequestedAt, t.RunRequestedBy = nil, ""
+	if !t.NextRunAt.After(now) {
+		t.NextRunAt = nextRunAt
+	}
+	return trigger, true, nil
+}
+
+func (s *memoryStore) FinishBackgroundTaskRun(_ context.Context, run *domain.BackgroundTaskRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, *run)
+	if t, ok := s.tasks[run.TaskName]; ok && t.LockedBy == run.WorkerID {
+		t.LockedBy, t.LockedUntil = "", nil
+	}
+	return nil
+}
+
+// requestRun asks for a run of task name, as the run-now endpoint does.
+func (s *memoryStore) requestRun(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.tasks[name]; t.RunRequestedAt == nil {
+		now := s.clock.Now()
+		t.RunRequestedAt, t.RunRequestedBy = &now, "admin"
+	}
+}
+
+// makeDue moves the next run of task name to the past.
+func (s *memoryStore) makeDue(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[name].NextRunAt = s.clock.Now().Add(-time.Minute)
+}
+
+func (s *memoryStore) history() []domain.BackgroundTaskRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]domain.BackgroundTaskRun(nil), s.runs...)
+}
+
+func (s *memoryStore) locked(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks[name].LockedBy != ""
+}
+
+// newTestRunner returns a runner of store on its clock without jitter, with
+// task registered.
+func newTestRunner(t *testing.T, store *memoryStore, workerID string, task Task) *Runner {
+	t.Helper()
+	r := NewRunner(store, workerID)
+	r.SetClock(store.clock)
+	r.jitter = func(time.Duration) time.Duration { return 0 }
+	r.Register(task)
+	require.NoError(t, r.register(context.Background()))
+	return r
+}
+
+func TestRunner_OneLockTwoRunners(t *testing.T) {
+	store := newMemoryStore()
+	var runs, concurrent, maxConcurrent atomic.Int32
+	release := make(chan struct{})
+	task := New("cleanup", Every(time.Hour), time.Minute, func(ctx context.Context) error {
+		n := concurrent.Add(1)
+		defer concurrent.Add(-1)
+		if n > maxConcurrent.Load() {
+			maxConcurrent.Store(n)
+		}
+		runs.Add(1)
+		<-release
+		return nil
+	})
+	a := newTestRunner(t, store, "worker-a", task)
+	b := newTestRunner(t, store, "worker-b", task)
+	store.makeDue("cleanup")
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for _, r := range []*Runner{a, b, a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.poll(ctx)
+		}()
+	}
+	wg.Wait()
+	require.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Polling again while the task runs claims nothing.
+	a.poll(ctx)
+	b.poll(ctx)
+	close(release)
+	a.runs.Wait()
+	b.runs.Wait()
+
+	assert.Equal(t, int32(1), runs.Load())
+	assert.Equal(t, int32(1), maxConcurrent.Load())
+	history := store.history()
+	require.Len(t, history, 1)
+	assert.Equal(t, domain.TaskTriggerSchedule, history[0].Trigger)
+	assert.False(t, store.locked("cleanup"), "the lock is released")
+
+	// Not due again until its next run.
+	a.poll(ctx)
+	b.poll(ctx)
+	a.runs.Wait()
+	b.runs.Wait()
+	assert.Len(t, store.history(), 1)
+}
+
+func TestRunner_RecordsRunOutcomes(t *testing.T) {
+	// ignored holds the task that ignores its timeout until the test ends.
+	ignored := make(chan struct{})
+	defer close(ignored)
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		fn      func(ctx context.Context) error
+		outcome string
+		err     string
+	}{
+		{"success", time.Minute, func(context.Context) error { return nil }, domain.TaskOutcomeSuccess, ""},
+		{"error", time.Minute, func(context.Context) error { return errors.New("clickhouse unavailable") }, domain.TaskOutcomeError, "clickhouse unavailable"},
+		{"panic", time.Minute, func(context.Context) error { panic("nil map") }, domain.TaskOutcomePanic, "panic: nil map"},
+		{"timeout honoured", 20 * time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, domain.TaskOutcomeTimeout, "timed out after 20ms: context deadline exceeded"},
+		{"timeout ignored", 20 * time.Millisecond, func(context.Context) error {
+			<-ignored
+			return nil
+		}, domain.TaskOutcomeTimeout, "timed out after 20ms: context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := newTestRunner(t, store, "worker-a", New("task", Every(time.Hour), tt.timeout, tt.fn))
+			store.makeDue("task")
+
+			r.poll(context.Background())
+			if tt.name == "timeout ignored" {
+				// The run is abandoned once its grace is over.
+				store.clock.BlockUntil(1)
+				store.clock.Advance(time.Second)
+			}
+			r.runs.Wait()
+
+			history := store.history()
+			require.Len(t, history, 1)
+			run := history[0]
+			assert.Equal(t, "task", run.TaskName)
+			assert.Equal(t, "worker-a", run.WorkerID)
+			assert.Equal(t, tt.outcome, run.Outcome)
+			assert.Equal(t, tt.err, run.Error)
+			assert.False(t, run.FinishedAt.Before(run.StartedAt))
+			assert.Equal(t, run.FinishedAt.Sub(run.StartedAt).Milliseconds(), run.DurationMS)
+			assert.False(t, store.locked("task"))
+		})
+	}
+}
+
+func TestRunner_RunNowDuringScheduledRun(t *testing.T) {
+	store := newMemoryStore()
+	var runs, concurrent atomic.Int32
+	var overlapped atomic.Bool
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	task := New("retention", Every(time.Hour), time.Minute, func(ctx context.Context) error {
+		if concurrent.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		defer concurrent.Add(-1)
+		runs.Add(1)
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	a := newTestRunner(t, store, "worker-a", task)
+	b := newTestRunner(t, store, "worker-b", task)
+	ctx := context.Background()
+
+	store.makeDue("retention")
+	a.poll(ctx)
+	<-started
+
+	// Requested while the scheduled run is in flight: no replica starts it
+	// until that run is over.
+	store.requestRun("retention")
+	a.poll(ctx)
+	b.poll(ctx)
+	assert.Equal(t, int32(1), runs.Load())
+
+	release <- struct{}{}
+	a.runs.Wait()
+
+	b.poll(ctx)
+	<-started
+	close(release)
+	b.runs.Wait()
+
+	// The request is consumed by one run.
+	a.poll(ctx)
+	b.poll(ctx)
+	a.runs.Wait()
+	b.runs.Wait()
+
+	assert.Equal(t, int32(2), runs.Load())
+	assert.False(t, overlapped.Load(), "runs never overlap")
+	history := store.history()
+	require.Len(t, history, 2)
+	assert.Equal(t, domain.TaskTriggerSchedule, history[0].Trigger)
+	assert.Equal(t, domain.TaskTriggerManual, history[1].Trigger)
+	assert.Equal(t, "worker-b", history[1].WorkerID)
+}
+
+func TestRunner_RegisterKeepsNextRun(t *testing.T) {
+	store := newMemoryStore()
+	task := New("digest", Every(time.Hour), 0, func(context.Context) error { return nil })
+	newTestRunner(t, store, "worker-a", task)
+	first := store.tasks["digest"].NextRunAt
+	assert.Equal(t, int(DefaultTimeout/time.Second), store.tasks["digest"].TimeoutSeconds)
+
+	store.clock.Advance(time.Minute)
+	newTestRunner(t, store, "worker-b", task)
+	assert.Equal(t, first, store.tasks["digest"].NextRunAt, "a restart does not postpone the task")
+
+	newTestRunner(t, store, "worker-b", New("digest", Every(2*time.Hour), 0, task.Run))
+	assert.True(t, store.tasks["digest"].NextRunAt.After(first), "a new schedule does")
+}
+
+func TestRunner_RunStopsOnCancel(t *testing.T) {
+	store := newMemoryStore()
+	r := NewRunner(store, "worker-a")
+	r.SetClock(store.clock)
+	r.SetPollInterval(time.Second)
+	ran := make(chan struct{})
+	var once sync.Once
+	r.Register(New("reaper", Every(time.Hour), time.Minute, func(ctx context.Context) error {
+		once.Do(func() { close(ran) })
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return store.tasks["reaper"] != nil
+	}, time.Second, 5*time.Millisecond)
+	store.requestRun("reaper")
+	// Polled before the request, the runner waits for its next poll.
+	store.clock.BlockUntil(1)
+	store.clock.Advance(2 * time.Second)
+	<-ran
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	history := store.history()
+	require.Len(t, history, 1)
+	assert.Equal(t, domain.TaskTriggerManual, history[0].Trigger)
+}
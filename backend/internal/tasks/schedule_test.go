@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvery(t *testing.T) {
+	now := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	s := Every(time.Hour)
+	assert.Equal(t, now.Add(time.Hour), s.Next(now))
+	assert.Equal(t, "@every 1h0m0s", s.String())
+	assert.Equal(t, "@every 1s", Every(0).String(), "clamped to a second")
+}
+
+func TestParseCron_Next(t *testing.T) {
+	// Tuesday 3 February 2026, 10:17:30 UTC.
+	now := time.Date(2026, 2, 3, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 2, 3, 10, 18, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 2, 4, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 2, 3, 10, 30, 0, 0, time.UTC)},
+		{"5,45 10-12 * * *", time.Date(2026, 2, 3, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches.
+		{"0 0 15 * 0", time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, s.Next(now), tt.expr)
+		assert.Equal(t, tt.expr, s.String())
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+	assert.Panics(t, func() { MustParseCron("bad") })
+}
@@ -0,0 +1,444 @@
+package tenantconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/worker"
+)
+
+// DefaultAdapters returns the adapters of every kind of tenant
+// configuration, in document order.
+func DefaultAdapters(pg storage.PostgresStore) []Adapter {
+	return []Adapter{
+		ThresholdRules(pg),
+		IngestionFilters(pg),
+		HealthProfile(pg),
+		SavedSearches(pg),
+	}
+}
+
+type actorKey struct{}
+
+// WithActor records the user applying a document, which stores that keep
+// an author (health profile versions) are given.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// --------------------------------------------------------------------------
+// Threshold rules
+// --------------------------------------------------------------------------
+
+// thresholdRuleSpec is a threshold rule in a document, keyed by name.
+type thresholdRuleSpec struct {
+	Name       string                     `yaml:"name" json:"name"`
+	Scope      domain.ThresholdScope      `yaml:"scope" json:"scope"`
+	ScopeValue string                     `yaml:"scope_value,omitempty" json:"scope_value,omitempty"`
+	Metric     domain.ThresholdMetric     `yaml:"metric" json:"metric"`
+	Comparator domain.ThresholdComparator `yaml:"comparator" json:"comparator"`
+	Value      float64                    `yaml:"value" json:"value"`
+	Severity   domain.ThresholdSeverity   `yaml:"severity" json:"severity"`
+	Enabled    bool                       `yaml:"enabled" json:"enabled"`
+}
+
+func (s thresholdRuleSpec) rule(tenantID uuid.UUID) *domain.ThresholdRule {
+	return &domain.ThresholdRule{
+		TenantID: tenantID, Name: s.Name, Scope: s.Scope, ScopeValue: s.ScopeValue, Metric: s.Metric,
+		Comparator: s.Comparator, Value: s.Value, Severity: s.Severity, Enabled: s.Enabled,
+	}
+}
+
+type thresholdRules struct{ pg storage.PostgresStore }
+
+// ThresholdRules adapts the tenant's threshold rules.
+func ThresholdRules(pg storage.PostgresStore) Adapter { return thresholdRules{pg: pg} }
+
+func (thresholdRules) Section() string    { return "threshold_rules" }
+func (thresholdRules) Default() *Resource { return nil }
+
+func (a thresholdRules) List(ctx context.Context, tenantID uuid.UUID) ([]Resource, error) {
+	rules, err := a.pg.ListThresholdRules(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]Resource, 0, len(rules))
+	for _, r := range rules {
+		resources = append(resources, Resource{Key: r.Name, ID: r.ID.String(), Spec: thresholdRuleSpec{
+			Name: r.Name, Scope: r.Scope, ScopeValue: r.ScopeValue, Metric: r.Metric,
+			Comparator: r.Comparator, Value: r.Value, Severity: r.Severity, Enabled: r.Enabled,
+		}})
+	}
+	return resources, nil
+}
+
+func (thresholdRules) Decode(node *yaml.Node) (Resource, error) {
+	spec := thresholdRuleSpec{Enabled: true}
+	if err := DecodeStrict(node, &spec); err != nil {
+		return Resource{}, err
+	}
+	rule := spec.rule(uuid.Nil)
+	if err := worker.ValidateThresholdRule(rule); err != nil {
+		return Resource{}, err
+	}
+	spec.Severity = rule.Severity
+	return Resource{Key: spec.Name, Spec: spec}, nil
+}
+
+func (a thresholdRules) Create(ctx context.Context, tenantID uuid.UUID, desired Resource) (Resource, error) {
+	rule := desired.Spec.(thresholdRuleSpec).rule(tenantID)
+	rule.ID, _ = uuid.Parse(desired.ID)
+	if err := a.pg.CreateThresholdRule(ctx, rule); err != nil {
+		return Resource{}, err
+	}
+	desired.ID = rule.ID.String()
+	return desired, nil
+}
+
+func (a thresholdRules) Update(ctx context.Context, tenantID uuid.UUID, current, desired Resource) (Resource, error) {
+	rule := desired.Spec.(thresholdRuleSpec).rule(tenantID)
+	id, err := uuid.Parse(current.ID)
+	if err != nil {
+		return Resource{}, fmt.Errorf("invalid threshold rule id %q", current.ID)
+	}
+	rule.ID = id
+	if err := a.pg.UpdateThresholdRule(ctx, rule); err != nil {
+		return Resource{}, err
+	}
+	desired.ID = current.ID
+	return desired, nil
+}
+
+func (a thresholdRules) Delete(ctx context.Context, tenantID uuid.UUID, current Resource) error {
+	id, err := uuid.Parse(current.ID)
+	if err != nil {
+		return fmt.Errorf("invalid threshold rule id %q", current.ID)
+	}
+	return a.pg.DeleteThresholdRule(ctx, tenantID, id)
+}
+
+// --------------------------------------------------------------------------
+// Ingestion filters
+// --------------------------------------------------------------------------
+
+// ingestionFilterSpec is an ingestion filter rule in a document, keyed by
+// name.
+type ingestionFilterSpec struct {
+	Name          string                         `yaml:"name" json:"name"`
+	Field         string                         `yaml:"field" json:"field"`
+	Operator      domain.IngestionFilterOperator `yaml:"operator" json:"operator"`
+	Value         string                         `yaml:"value" json:"value"`
+	CaseSensitive bool                           `yaml:"case_sensitive" json:"case_sensitive"`
+	Action        domain.IngestionFilterAction   `yaml:"action" json:"action"`
+	Enabled       bool                           `yaml:"enabled" json:"enabled"`
+}
+
+func (s ingestionFilterSpec) rule(tenantID uuid.UUID) *domain.IngestionFilterRule {
+	return &domain.IngestionFilterRule{
+		TenantID: tenantID, Name: s.Name, Field: s.Field, Operator: s.Operator, Value: s.Value,
+		CaseSensitive: s.CaseSensitive, Action: s.Action, Enabled: s.Enabled,
+	}
+}
+
+type ingestionFilters struct{ pg storage.PostgresStore }
+
+// IngestionFilters adapts the tenant's ingestion filter rules.
+func IngestionFilters(pg storage.PostgresStore) Adapter { return ingestionFilters{pg: pg} }
+
+func (ingestionFilters) Section() string    { return "ingestion_filters" }
+func (ingestionFilters) Default() *Resource { return nil }
+
+func (a ingestionFilters) List(ctx context.Context, tenantID uuid.UUID) ([]Resource, error) {
+	rules, err := a.pg.ListIngestionFilterRules(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]Resource, 0, len(rules))
+	for _, r := range rules {
+		resources = append(resources, Resource{Key: r.Name, ID: r.ID.String(), Spec: ingestionFilterSpec{
+			Name: r.Name, Field: r.Field, Operator: r.Operator, Value: r.Value,
+			CaseSensitive: r.CaseSensitive, Action: r.Action, Enabled: r.Enabled,
+		}})
+	}
+	return resources, nil
+}
+
+func (ingestionFilters) Decode(node *yaml.Node) (Resource, error) {
+	spec := ingestionFilterSpec{Enabled: true}
+	if err := DecodeStrict(node, &spec); err != nil {
+		return Resource{}, err
+	}
+	rule := spec.rule(uuid.Nil)
+	if err := worker.ValidateIngestionFilterRule(rule); err != nil {
+		return Resource{}, err
+	}
+	spec.Action = rule.Action
+	return Resource{Key: spec.Name, Spec: spec}, nil
+}
+
+func (a ingestionFilters) Create(ctx context.Context, tenantID uuid.UUID, desired Resource) (Resource, error) {
+	rule := desired.Spec.(ingestionFilterSpec).rule(tenantID)
+	rule.ID, _ = uuid.Parse(desired.ID)
+	if err := a.pg.CreateIngestionFilterRule(ctx, rule); err != nil {
+		return Resource{}, err
+	}
+	desired.ID = rule.ID.String()
+	return desired, nil
+}
+
+func (a ingestionFilters) Update(ctx context.Context, tenantID uuid.UUID, current, desired Resource) (Resource, error) {
+	rule := desired.Spec.(ingestionFilterSpec).rule(tenantID)
+	id, err := uuid.Parse(current.ID)
+	if err != nil {
+		return Resource{}, fmt.Errorf("invalid ingestion filter rule id %q", current.ID)
+	}
+	rule.ID = id
+	if err := a.pg.UpdateIngestionFilterRule(ctx, rule); err != nil {
+		return Resource{}, err
+	}
+	desired.ID = current.ID
+	return desired, nil
+}
+
+func (a ingestionFilters) Delete(ctx context.Context, tenantID uuid.UUID, current Resource) error {
+	id, err := uuid.Parse(current.ID)
+	if err != nil {
+		return fmt.Errorf("invalid ingestion filter rule id %q", current.ID)
+	}
+	return a.pg.DeleteIngestionFilterRule(ctx, tenantID, id)
+}
+
+// --------------------------------------------------------------------------
+// Health profile
+// --------------------------------------------------------------------------
+
+// healthProfileSpec is the tenant's health profile in a document. The
+// section holds one profile; removing it resets the tenant to the default.
+type healthProfileSpec struct {
+	Factors    []healthFactorSpec `yaml:"factors" json:"factors"`
+	RedBelow   int                `yaml:"red_below" json:"red_below"`
+	GreenAbove int                `yaml:"green_above" json:"green_above"`
+}
+
+type healthFactorSpec struct {
+	Name           domain.HealthFactorName `yaml:"name" json:"name"`
+	Weight         float64                 `yaml:"weight" json:"weight"`
+	Disabled       bool                    `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Breakpoints    []healthBreakpointSpec  `yaml:"breakpoints" json:"breakpoints"`
+	OtherwiseScore int                     `yaml:"otherwise_score" json:"otherwise_score"`
+}
+
+type healthBreakpointSpec struct {
+	Below float64 `yaml:"below" json:"below"`
+	Score int     `yaml:"score" json:"score"`
+}
+
+func healthSpecFrom(p *domain.HealthProfile) healthProfileSpec {
+	spec := healthProfileSpec{RedBelow: p.RedBelow, GreenAbove: p.GreenAbove}
+	for _, f := range p.Factors {
+		fs := healthFactorSpec{Name: f.Name, Weight: f.Weight, Disabled: f.Disabled, OtherwiseScore: f.OtherwiseScore}
+		for _, b := range f.Breakpoints {
+			fs.Breakpoints = append(fs.Breakpoints, healthBreakpointSpec{Below: b.Below, Score: b.Score})
+		}
+		spec.Factors = append(spec.Factors, fs)
+	}
+	return spec
+}
+
+func (s healthProfileSpec) profile(tenantID uuid.UUID) *domain.HealthProfile {
+	p := &domain.HealthProfile{TenantID: tenantID, RedBelow: s.RedBelow, GreenAbove: s.GreenAbove}
+	for _, f := range s.Factors {
+		fc := domain.HealthFactorConfig{Name: f.Name, Weight: f.Weight, Disabled: f.Disabled, OtherwiseScore: f.OtherwiseScore}
+		for _, b := range f.Breakpoints {
+			fc.Breakpoints = append(fc.Breakpoints, domain.HealthBreakpoint{Below: b.Below, Score: b.Score})
+		}
+		p.Factors = append(p.Factors, fc)
+	}
+	return p
+}
+
+type healthProfile struct{ pg storage.PostgresStore }
+
+// HealthProfile adapts the tenant's health score profile.
+func HealthProfile(pg storage.PostgresStore) Adapter { return healthProfile{pg: pg} }
+
+// healthProfileKey is the key of the single resource of the section.
+const healthProfileKey = "health_profile"
+
+func (healthProfile) Section() string { return "health_profile" }
+
+func (healthProfile) Default() *Resource {
+	return &Resource{Key: healthProfileKey, Spec: healthSpecFrom(storage.DefaultHealthProfile())}
+}
+
+func (a healthProfile) List(ctx context.Context, tenantID uuid.UUID) ([]Resource, error) {
+	p, err := storage.LoadHealthProfile(ctx, a.pg, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return []Resource{{Key: healthProfileKey, ID: strconv.Itoa(p.Version), Spec: healthSpecFrom(p)}}, nil
+}
+
+func (healthProfile) Decode(node *yaml.Node) (Resource, error) {
+	var spec healthProfileSpec
+	if err := DecodeStrict(node, &spec); err != nil {
+		return Resource{}, err
+	}
+	if err := storage.ValidateHealthProfile(spec.profile(uuid.Nil)); err != nil {
+		return Resource{}, err
+	}
+	return Resource{Key: healthProfileKey, Spec: spec}, nil
+}
+
+// store writes spec as the next version of the profile. The latest version
+// is read again rather than taken from the resource, as a rollback writes
+// over versions stored since it was listed.
+func (a healthProfile) store(ctx context.Context, tenantID uuid.UUID, spec healthProfileSpec) (Resource, error) {
+	current, err := storage.LoadHealthProfile(ctx, a.pg, tenantID)
+	if err != nil {
+		return Resource{}, err
+	}
+	p := spec.profile(tenantID)
+	p.CreatedBy = actorFrom(ctx)
+	if err := a.pg.CreateHealthProfile(ctx, p, current.Version); err != nil {
+		return Resource{}, err
+	}
+	return Resource{Key: healthProfileKey, ID: strconv.Itoa(p.Version), Spec: spec}, nil
+}
+
+func (a healthProfile) Create(ctx context.Context, tenantID uuid.UUID, desired Resource) (Resource, error) {
+	return a.store(ctx, tenantID, desired.Spec.(healthProfileSpec))
+}
+
+func (a healthProfile) Update(ctx context.Context, tenantID uuid.UUID, _, desired Resource) (Resource, error) {
+	return a.store(ctx, tenantID, desired.Spec.(healthProfileSpec))
+}
+
+func (a healthProfile) Delete(ctx context.Context, tenantID uuid.UUID, _ Resource) error {
+	_, err := a.store(ctx, tenantID, a.Default().Spec.(healthProfileSpec))
+	return err
+}
+
+// --------------------------------------------------------------------------
+// Saved searches
+// --------------------------------------------------------------------------
+
+// savedSearchSpec is a saved search in a document, keyed by owner and name.
+// Filters holds the JSON filters of the search as YAML.
+type savedSearchSpec struct {
+	Owner    string `yaml:"owner" json:"owner"`
+	Name     string `yaml:"name" json:"name"`
+	KQLQuery string `yaml:"kql_query" json:"kql_query"`
+	Filters  any    `yaml:"filters,omitempty" json:"filters,omitempty"`
+	IsPinned bool   `yaml:"is_pinned,omitempty" json:"is_pinned,omitempty"`
+}
+
+func (s savedSearchSpec) key() string { return s.Owner + "/" + s.Name }
+
+type savedSearches struct{ pg storage.PostgresStore }
+
+// SavedSearches adapts the saved searches of every user of the tenant.
+func SavedSearches(pg storage.PostgresStore) Adapter { return savedSearches{pg: pg} }
+
+func (savedSearches) Section() string    { return "saved_searches" }
+func (savedSearches) Default() *Resource { return nil }
+
+func (a savedSearches) List(ctx context.Context, tenantID uuid.UUID) ([]Resource, error) {
+	searches, err := a.pg.ListTenantSavedSearches(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]Resource, 0, len(searches))
+	for _, s := range searches {
+		spec := savedSearchSpec{Owner: s.UserID, Name: s.Name, KQLQuery: s.KQLQuery, IsPinned: s.IsPinned}
+		if len(s.Filters) > 0 {
+			if err := json.Unmarshal(s.Filters, &spec.Filters); err != nil {
+				return nil, fmt.Errorf("saved search %s: decode filters: %w", s.ID, err)
+			}
+		}
+		resources = append(resources, Resource{Key: spec.key(), ID: s.ID.String(), Spec: spec})
+	}
+	return resources, nil
+}
+
+func (savedSearches) Decode(node *yaml.Node) (Resource, error) {
+	var spec savedSearchSpec
+	if err := DecodeStrict(node, &spec); err != nil {
+		return Resource{}, err
+	}
+	switch {
+	case spec.Owner == "":
+		return Resource{}, &FieldError{Field: "owner", Node: node, Message: "owner is required"}
+	case spec.Name == "":
+		return Resource{}, &FieldError{Field: "name", Node: node, Message: "name is required"}
+	case spec.KQLQuery == "":
+		return Resource{}, &FieldError{Field: "kql_query", Node: node, Message: "kql_query is required"}
+	}
+	// Filters go through JSON so that they compare equal to stored ones.
+	if spec.Filters != nil {
+		raw, err := json.Marshal(spec.Filters)
+		if err != nil {
+			return Resource{}, &FieldError{Field: "filters", Node: node, Message: "filters must be JSON-compatible"}
+		}
+		spec.Filters = nil
+		if err := json.Unmarshal(raw, &spec.Filters); err != nil {
+			return Resource{}, &FieldError{Field: "filters", Node: node, Message: err.Error()}
+		}
+	}
+	return Resource{Key: spec.key(), Spec: spec}, nil
+}
+
+func (a savedSearches) Create(ctx context.Context, tenantID uuid.UUID, desired Resource) (Resource, error) {
+	spec := desired.Spec.(savedSearchSpec)
+	s := &domain.SavedSearch{TenantID: tenantID, UserID: spec.Owner, Name: spec.Name, KQLQuery: spec.KQLQuery, IsPinned: spec.IsPinned}
+	s.ID, _ = uuid.Parse(desired.ID)
+	if spec.Filters != nil {
+		raw, err := json.Marshal(spec.Filters)
+		if err != nil {
+			return Resource{}, fmt.Errorf("encode filters: %w", err)
+		}
+		s.Filters = raw
+	}
+	if err := a.pg.CreateSavedSearch(ctx, s); err != nil {
+		return Resource{}, err
+	}
+	desired.ID = s.ID.String()
+	return desired, nil
+}
+
+// Update replaces the saved search, as saved searches are not edited in
+// place: the new one is stored before the old one is removed.
+func (a savedSearches) Update(ctx context.Context, tenantID uuid.UUID, current, desired Resource) (Resource, error) {
+	desired.ID = ""
+	created, err := a.Create(ctx, tenantID, desired)
+	if err != nil {
+		return Resource{}, err
+	}
+	if err := a.Delete(ctx, tenantID, current); err != nil {
+		if undoErr := a.Delete(ctx, tenantID, created); undoErr != nil {
+			return Resource{}, fmt.Errorf("%w (and removing the replacement failed: %v)", err, undoErr)
+		}
+		return Resource{}, err
+	}
+	return created, nil
+}
+
+func (a savedSearches) Delete(ctx context.Context, tenantID uuid.UUID, current Resource) error {
+	id, err := uuid.Parse(current.ID)
+	if err != nil {
+		return fmt.Errorf("invalid saved search id %q", current.ID)
+	}
+	return a.pg.DeleteSavedSearch(ctx, tenantID, current.Spec.(savedSearchSpec).Owner, id)
+}
@@ -0,0 +1,123 @@
+package tenantconfig
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is an error in one resource of a document. Field is the path
+// below the resource (factors[1].weight), empty when the error concerns
+// the resource as a whole.
+type FieldError struct {
+	Field   string
+	Node    *yaml.Node
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// DecodeStrict decodes a resource node into out, a pointer to a struct with
+// yaml tags. Unlike yaml.Node.Decode it rejects fields out does not have,
+// and it reports the path of the first field that is missing from out or
+// does not decode into it.
+func DecodeStrict(node *yaml.Node, out any) error {
+	if err := checkNode(node, reflect.TypeOf(out).Elem(), ""); err != nil {
+		return err
+	}
+	if err := node.Decode(out); err != nil {
+		return &FieldError{Node: node, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	return nil
+}
+
+// checkNode checks node against the type it is decoded into.
+func checkNode(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return &FieldError{Field: path, Node: node, Message: "must be a mapping"}
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			f, ok := fields[key.Value]
+			if !ok {
+				return &FieldError{Field: join(path, key.Value), Node: key, Message: "unknown field"}
+			}
+			if err := checkNode(value, f.Type, join(path, key.Value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			return nil
+		}
+		if node.Kind != yaml.SequenceNode {
+			return &FieldError{Field: path, Node: node, Message: "must be a list"}
+		}
+		for i, item := range node.Content {
+			if err := checkNode(item, t.Elem(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Interface:
+		return nil
+	}
+
+	if err := node.Decode(reflect.New(t).Interface()); err != nil {
+		msg := "must be a " + t.Kind().String()
+		if t.Kind() == reflect.Float64 || t.Kind() == reflect.Int {
+			msg = "must be a number"
+		}
+		if t.Kind() == reflect.Bool {
+			msg = "must be true or false"
+		}
+		return &FieldError{Field: path, Node: node, Message: msg}
+	}
+	return nil
+}
+
+// yamlFields maps the yaml names of the fields of a struct type to the
+// fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,518 @@
+// Package tenantconfig exports the declarative configuration of a tenant as
+// a single YAML document and applies such documents back, so that the
+// configuration of several environments can be kept in version control.
+//
+// Each kind of configuration is handled by an Adapter over its existing
+// store. Resources are matched by a stable key (a rule's name, say), never
+// by their database IDs, so a document exported from one tenant can be
+// applied to another. Data and secrets are never part of a document.
+package tenantconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// APIVersion is the api_version of the documents this release reads
+	// and writes.
+	APIVersion = "remedyiq/v1"
+	// Kind is the kind of a tenant configuration document.
+	Kind = "TenantConfig"
+)
+
+// Resource is one declarative configuration object.
+type Resource struct {
+	// Key identifies the resource within its section across tenants.
+	Key string
+	// ID is the store's identity of an existing resource; it is empty for
+	// resources read from a document.
+	ID string
+	// Spec holds the declarative fields, encoded as the item of the
+	// section. Two resources are equal when their specs encode alike.
+	Spec any
+}
+
+// Adapter exposes one kind of tenant configuration, a section of the
+// document, over its store.
+type Adapter interface {
+	// Section is the document key of the resources.
+	Section() string
+	// Default returns the resource of a section that holds a single
+	// resource, which a tenant has until it configures its own, or nil for
+	// sections that hold a list.
+	Default() *Resource
+	// List returns the tenant's resources. A single-resource section
+	// always returns one, the default if nothing is stored.
+	List(ctx context.Context, tenantID uuid.UUID) ([]Resource, error)
+	// Decode decodes and validates one resource of a document.
+	Decode(node *yaml.Node) (Resource, error)
+	// Create stores desired, keeping its ID if set, and returns the
+	// stored resource.
+	Create(ctx context.Context, tenantID uuid.UUID, desired Resource) (Resource, error)
+	// Update replaces current with desired and returns the stored
+	// resource.
+	Update(ctx context.Context, tenantID uuid.UUID, current, desired Resource) (Resource, error)
+	// Delete removes current; a single-resource section is reset to its
+	// default.
+	Delete(ctx context.Context, tenantID uuid.UUID, current Resource) error
+}
+
+// Manager exports and imports tenant configuration documents.
+type Manager struct {
+	adapters []Adapter
+}
+
+// NewManager creates a manager over the adapters, whose sections are
+// written and applied in the order given.
+func NewManager(adapters ...Adapter) *Manager {
+	return &Manager{adapters: adapters}
+}
+
+// Export returns the tenant's configuration as a canonical YAML document:
+// sections in adapter order and resources sorted by key, so that exporting
+// an unchanged configuration always yields the same bytes.
+func (m *Manager) Export(ctx context.Context, tenantID uuid.UUID) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	addScalar(root, "api_version", APIVersion)
+	addScalar(root, "kind", Kind)
+
+	for _, a := range m.adapters {
+		resources, err := a.List(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("tenantconfig: list %s: %w", a.Section(), err)
+		}
+		sortByKey(resources)
+
+		var value *yaml.Node
+		if a.Default() != nil {
+			if len(resources) == 0 {
+				continue
+			}
+			if value, err = encodeSpec(resources[0].Spec); err != nil {
+				return nil, fmt.Errorf("tenantconfig: encode %s: %w", a.Section(), err)
+			}
+		} else {
+			value = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, r := range resources {
+				item, err := encodeSpec(r.Spec)
+				if err != nil {
+					return nil, fmt.Errorf("tenantconfig: encode %s %q: %w", a.Section(), r.Key, err)
+				}
+				value.Content = append(value.Content, item)
+			}
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: a.Section()}, value)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}); err != nil {
+		return nil, fmt.Errorf("tenantconfig: encode document: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("tenantconfig: encode document: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Document is a parsed and validated configuration document.
+type Document struct {
+	sections map[string][]Resource
+}
+
+// Parse decodes and validates a document. All problems found are returned
+// together as ValidationErrors.
+func (m *Manager) Parse(data []byte) (*Document, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, ValidationErrors{syntaxError(err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil, ValidationErrors{{Path: "$", Message: "document is empty"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, ValidationErrors{errorAt(root, "$", "document must be a mapping")}
+	}
+
+	adapters := make(map[string]Adapter, len(m.adapters))
+	for _, a := range m.adapters {
+		adapters[a.Section()] = a
+	}
+
+	var errs ValidationErrors
+	d := &Document{sections: make(map[string][]Resource)}
+	var sawVersion, sawKind bool
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, value := root.Content[i], root.Content[i+1]
+		name := keyNode.Value
+		switch name {
+		case "api_version":
+			sawVersion = true
+			if value.Value != APIVersion {
+				errs = append(errs, errorAt(value, name, fmt.Sprintf("unsupported api_version %q; expected %s", value.Value, APIVersion)))
+			}
+			continue
+		case "kind":
+			sawKind = true
+			if value.Value != Kind {
+				errs = append(errs, errorAt(value, name, fmt.Sprintf("kind must be %s", Kind)))
+			}
+			continue
+		}
+
+		a, ok := adapters[name]
+		if !ok {
+			errs = append(errs, errorAt(keyNode, name, "unknown section"))
+			continue
+		}
+		if _, dup := d.sections[name]; dup {
+			errs = append(errs, errorAt(keyNode, name, "section given twice"))
+			continue
+		}
+		resources, sectionErrs := decodeSection(a, value)
+		errs = append(errs, sectionErrs...)
+		d.sections[name] = resources
+	}
+	if !sawVersion {
+		errs = append(errs, errorAt(root, "api_version", "api_version is required"))
+	}
+	if !sawKind {
+		errs = append(errs, errorAt(root, "kind", "kind is required"))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return d, nil
+}
+
+// decodeSection decodes the resources of one section, rejecting keys given
+// twice.
+func decodeSection(a Adapter, value *yaml.Node) ([]Resource, ValidationErrors) {
+	section := a.Section()
+	if a.Default() != nil {
+		if value.Kind != yaml.MappingNode {
+			return nil, ValidationErrors{errorAt(value, section, "must be a mapping")}
+		}
+		r, err := a.Decode(value)
+		if err != nil {
+			return nil, ValidationErrors{validationError(err, value, section)}
+		}
+		return []Resource{r}, nil
+	}
+
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+		return []Resource{}, nil
+	}
+	if value.Kind != yaml.SequenceNode {
+		return nil, ValidationErrors{errorAt(value, section, "must be a list")}
+	}
+	var errs ValidationErrors
+	resources := make([]Resource, 0, len(value.Content))
+	seen := make(map[string]bool, len(value.Content))
+	for i, item := range value.Content {
+		path := section + "[" + strconv.Itoa(i) + "]"
+		r, err := a.Decode(item)
+		if err != nil {
+			errs = append(errs, validationError(err, item, path))
+			continue
+		}
+		if seen[r.Key] {
+			errs = append(errs, errorAt(item, path, fmt.Sprintf("%q is given more than once", r.Key)))
+			continue
+		}
+		seen[r.Key] = true
+		resources = append(resources, r)
+	}
+	return resources, errs
+}
+
+// Action is what an import does to one resource.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Change is one difference between a document and the stored
+// configuration. Before and After are the specs, absent for creates and
+// deletes respectively.
+type Change struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	Action  Action `json:"action"`
+	Before  any    `json:"before,omitempty"`
+	After   any    `json:"after,omitempty"`
+
+	current, desired Resource
+}
+
+// Plan returns the changes that applying doc makes, section by section and
+// by key within a section. Resources missing from the document are only
+// deleted when prune is set, and sections missing from it are left alone
+// unless prune is set.
+func (m *Manager) Plan(ctx context.Context, tenantID uuid.UUID, doc *Document, prune bool) ([]Change, error) {
+	var changes []Change
+	for _, a := range m.adapters {
+		section := a.Section()
+		desired, inDoc := doc.sections[section]
+		if !inDoc && !prune {
+			continue
+		}
+		current, err := a.List(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("tenantconfig: list %s: %w", section, err)
+		}
+
+		if def := a.Default(); def != nil {
+			switch {
+			case len(current) == 0:
+				// The adapter always lists a single resource; nothing to
+				// compare against otherwise.
+			case inDoc && !sameSpec(current[0], desired[0]):
+				changes = append(changes, Change{Section: section, Key: current[0].Key, Action: ActionUpdate,
+					Before: current[0].Spec, After: desired[0].Spec, current: current[0], desired: desired[0]})
+			case !inDoc && !sameSpec(current[0], *def):
+				changes = append(changes, Change{Section: section, Key: current[0].Key, Action: ActionDelete,
+					Before: current[0].Spec, current: current[0]})
+			}
+			continue
+		}
+
+		// A key the store holds more than once matches its first resource;
+		// the others are left to prune.
+		byKey := make(map[string]Resource, len(current))
+		var extra []Resource
+		sortByKey(current)
+		for _, r := range current {
+			if _, dup := byKey[r.Key]; dup {
+				extra = append(extra, r)
+				continue
+			}
+			byKey[r.Key] = r
+		}
+
+		var sectionChanges []Change
+		for _, d := range desired {
+			c, ok := byKey[d.Key]
+			switch {
+			case !ok:
+				sectionChanges = append(sectionChanges, Change{Section: section, Key: d.Key, Action: ActionCreate,
+					After: d.Spec, desired: d})
+			case !sameSpec(c, d):
+				sectionChanges = append(sectionChanges, Change{Section: section, Key: d.Key, Action: ActionUpdate,
+					Before: c.Spec, After: d.Spec, current: c, desired: d})
+			}
+			delete(byKey, d.Key)
+		}
+		if prune {
+			for _, c := range byKey {
+				extra = append(extra, c)
+			}
+			for _, c := range extra {
+				sectionChanges = append(sectionChanges, Change{Section: section, Key: c.Key, Action: ActionDelete,
+					Before: c.Spec, current: c})
+			}
+		}
+		sort.SliceStable(sectionChanges, func(i, j int) bool { return sectionChanges[i].Key < sectionChanges[j].Key })
+		changes = append(changes, sectionChanges...)
+	}
+	return changes, nil
+}
+
+// SectionFailure reports the section an import stopped at.
+type SectionFailure struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	Error   string `json:"error"`
+	// RolledBack are the changes of the section that were undone.
+	RolledBack []Change `json:"rolled_back"`
+	// RollbackErrors are the changes that could not be undone, which are
+	// left applied.
+	RollbackErrors []string `json:"rollback_errors,omitempty"`
+}
+
+// Result reports the outcome of Apply.
+type Result struct {
+	Applied []Change        `json:"applied"`
+	Failed  *SectionFailure `json:"failed,omitempty"`
+}
+
+// Apply applies changes as planned by Plan, section by section. A section
+// is all or nothing: when one of its changes fails, those already made are
+// undone and the import stops, leaving the sections before it applied.
+func (m *Manager) Apply(ctx context.Context, tenantID uuid.UUID, changes []Change) *Result {
+	adapters := make(map[string]Adapter, len(m.adapters))
+	for _, a := range m.adapters {
+		adapters[a.Section()] = a
+	}
+
+	res := &Result{Applied: []Change{}}
+	for start := 0; start < len(changes); {
+		section := changes[start].Section
+		end := start
+		for end < len(changes) && changes[end].Section == section {
+			end++
+		}
+		a := adapters[section]
+
+		var undo []func() error
+		var done []Change
+		for _, c := range changes[start:end] {
+			u, err := apply(ctx, a, tenantID, c)
+			if err != nil {
+				failure := &SectionFailure{Section: section, Key: c.Key, Error: err.Error(), RolledBack: []Change{}}
+				for i := len(undo) - 1; i >= 0; i-- {
+					if err := undo[i](); err != nil {
+						failure.RollbackErrors = append(failure.RollbackErrors, fmt.Sprintf("%s %q: %v", done[i].Action, done[i].Key, err))
+						res.Applied = append(res.Applied, done[i])
+						continue
+					}
+					failure.RolledBack = append(failure.RolledBack, done[i])
+				}
+				res.Failed = failure
+				return res
+			}
+			undo = append(undo, u)
+			done = append(done, c)
+		}
+		res.Applied = append(res.Applied, done...)
+		start = end
+	}
+	return res
+}
+
+// apply makes one change and returns how to undo it.
+func apply(ctx context.Context, a Adapter, tenantID uuid.UUID, c Change) (func() error, error) {
+	switch c.Action {
+	case ActionCreate:
+		created, err := a.Create(ctx, tenantID, c.desired)
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return a.Delete(ctx, tenantID, created) }, nil
+	case ActionUpdate:
+		updated, err := a.Update(ctx, tenantID, c.current, c.desired)
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			_, err := a.Update(ctx, tenantID, updated, c.current)
+			return err
+		}, nil
+	case ActionDelete:
+		if err := a.Delete(ctx, tenantID, c.current); err != nil {
+			return nil, err
+		}
+		if a.Default() != nil {
+			return func() error {
+				_, err := a.Update(ctx, tenantID, *a.Default(), c.current)
+				return err
+			}, nil
+		}
+		return func() error {
+			_, err := a.Create(ctx, tenantID, c.current)
+			return err
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown action %q", c.Action)
+}
+
+// sameSpec reports whether two resources encode alike.
+func sameSpec(a, b Resource) bool {
+	x, errX := yaml.Marshal(a.Spec)
+	y, errY := yaml.Marshal(b.Spec)
+	return errX == nil && errY == nil && bytes.Equal(x, y)
+}
+
+func sortByKey(resources []Resource) {
+	sort.SliceStable(resources, func(i, j int) bool { return resources[i].Key < resources[j].Key })
+}
+
+func addScalar(m *yaml.Node, key, value string) {
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: value})
+}
+
+func encodeSpec(spec any) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(spec); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ValidationError is a problem with a document, located by its path
+// (threshold_rules[2].metric) and, when known, its line and column.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %s", e.Path, e.Line, e.Message)
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors are all the problems found in a document.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func errorAt(n *yaml.Node, path, msg string) ValidationError {
+	return ValidationError{Path: path, Line: n.Line, Column: n.Column, Message: msg}
+}
+
+// validationError places an error returned by Adapter.Decode for the item
+// at path. Field errors carry their own node and the path below the item.
+func validationError(err error, item *yaml.Node, path string) ValidationError {
+	if fe, ok := err.(*FieldError); ok {
+		if fe.Field != "" {
+			path += "." + fe.Field
+		}
+		n := item
+		if fe.Node != nil {
+			n = fe.Node
+		}
+		return errorAt(n, path, fe.Message)
+	}
+	return errorAt(item, path, err.Error())
+}
+
+// syntaxError turns a YAML parse error into a validation error, keeping
+// the line the parser reports.
+func syntaxError(err error) ValidationError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	v := ValidationError{Path: "$", Message: msg}
+	if rest, ok := strings.CutPrefix(msg, "line "); ok {
+		if i := strings.Index(rest, ":"); i > 0 {
+			if line, err := strconv.Atoi(rest[:i]); err == nil {
+				v.Line = line
+				v.Message = strings.TrimSpace(rest[i+1:])
+			}
+		}
+	}
+	return v
+}
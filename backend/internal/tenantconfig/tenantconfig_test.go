@@ -0,0 +1,500 @@
+package tenantconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// memStore keeps the configuration stores in memory. Methods the adapters
+// do not use fall through to the mock, which panics. fail makes the n-th
+// call (from 1) of a method fail.
+type memStore struct {
+	*testutil.MockPostgresStore
+	thresholds map[uuid.UUID]domain.ThresholdRule
+	filters    map[uuid.UUID]domain.IngestionFilterRule
+	profiles   []domain.HealthProfile
+	searches   map[uuid.UUID]domain.SavedSearch
+	calls      map[string]int
+	fail       map[string]int
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		MockPostgresStore: new(testutil.MockPostgresStore),
+		thresholds:        map[uuid.UUID]domain.ThresholdRule{},
+		filters:           map[uuid.UUID]domain.IngestionFilterRule{},
+		searches:          map[uuid.UUID]domain.SavedSearch{},
+		calls:             map[string]int{},
+		fail:              map[string]int{},
+	}
+}
+
+func (s *memStore) failing(method string) error {
+	s.calls[method]++
+	if s.fail[method] == s.calls[method] {
+		return fmt.Errorf("postgres: %s: connection reset", method)
+	}
+	return nil
+}
+
+func (s *memStore) ListThresholdRules(_ context.Context, tenantID uuid.UUID) ([]domain.ThresholdRule, error) {
+	var out []domain.ThresholdRule
+	for _, r := range s.thresholds {
+		if r.TenantID == tenantID {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID.String() < out[j].ID.String() })
+	return out, nil
+}
+
+func (s *memStore) CreateThresholdRule(_ context.Context, r *domain.ThresholdRule) error {
+	if err := s.failing("CreateThresholdRule"); err != nil {
+		return err
+	}
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	s.thresholds[r.ID] = *r
+	return nil
+}
+
+func (s *memStore) UpdateThresholdRule(_ context.Context, r *domain.ThresholdRule) error {
+	if err := s.failing("UpdateThresholdRule"); err != nil {
+		return err
+	}
+	s.thresholds[r.ID] = *r
+	return nil
+}
+
+func (s *memStore) DeleteThresholdRule(_ context.Context, _ uuid.UUID, id uuid.UUID) error {
+	if err := s.failing("DeleteThresholdRule"); err != nil {
+		return err
+	}
+	delete(s.thresholds, id)
+	return nil
+}
+
+func (s *memStore) ListIngestionFilterRules(_ context.Context, tenantID uuid.UUID) ([]domain.IngestionFilterRule, error) {
+	var out []domain.IngestionFilterRule
+	for _, r := range s.filters {
+		if r.TenantID == tenantID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) CreateIngestionFilterRule(_ context.Context, r *domain.IngestionFilterRule) error {
+	if err := s.failing("CreateIngestionFilterRule"); err != nil {
+		return err
+	}
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	s.filters[r.ID] = *r
+	return nil
+}
+
+func (s *memStore) UpdateIngestionFilterRule(_ context.Context, r *domain.IngestionFilterRule) error {
+	if err := s.failing("UpdateIngestionFilterRule"); err != nil {
+		return err
+	}
+	s.filters[r.ID] = *r
+	return nil
+}
+
+func (s *memStore) DeleteIngestionFilterRule(_ context.Context, _ uuid.UUID, id uuid.UUID) error {
+	if err := s.failing("DeleteIngestionFilterRule"); err != nil {
+		return err
+	}
+	delete(s.filters, id)
+	return nil
+}
+
+func (s *memStore) GetHealthProfile(_ context.Context, tenantID uuid.UUID) (*domain.HealthProfile, error) {
+	if len(s.profiles) == 0 {
+		return nil, fmt.Errorf("postgres: health profile not found: %s", tenantID)
+	}
+	p := s.profiles[len(s.profiles)-1]
+	return &p, nil
+}
+
+func (s *memStore) CreateHealthProfile(_ context.Context, hp *domain.HealthProfile, expectedVersion int) error {
+	if err := s.failing("CreateHealthProfile"); err != nil {
+		return err
+	}
+	if expectedVersion != len(s.profiles) {
+		return storage.ErrVersionConflict
+	}
+	hp.Version = expectedVersion + 1
+	s.profiles = append(s.profiles, *hp)
+	return nil
+}
+
+func (s *memStore) ListTenantSavedSearches(_ context.Context, tenantID uuid.UUID) ([]domain.SavedSearch, error) {
+	var out []domain.SavedSearch
+	for _, ss := range s.searches {
+		if ss.TenantID == tenantID {
+			out = append(out, ss)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) CreateSavedSearch(_ context.Context, ss *domain.SavedSearch) error {
+	if err := s.failing("CreateSavedSearch"); err != nil {
+		return err
+	}
+	if ss.ID == uuid.Nil {
+		ss.ID = uuid.New()
+	}
+	s.searches[ss.ID] = *ss
+	return nil
+}
+
+func (s *memStore) DeleteSavedSearch(_ context.Context, _ uuid.UUID, userID string, id uuid.UUID) error {
+	if err := s.failing("DeleteSavedSearch"); err != nil {
+		return err
+	}
+	if s.searches[id].UserID != userID {
+		return fmt.Errorf("postgres: saved search not found: %s", id)
+	}
+	delete(s.searches, id)
+	return nil
+}
+
+var tenantA = uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000001")
+
+// seed stores one of each kind of configuration.
+func seed(s *memStore, tenantID uuid.UUID) {
+	for _, r := range []domain.ThresholdRule{
+		{Name: "slow api", Scope: domain.ThresholdScopeLogType, ScopeValue: "API", Metric: domain.ThresholdMetricP95,
+			Comparator: domain.ThresholdComparatorGT, Value: 2000, Severity: domain.ThresholdSeverityWarning, Enabled: true},
+		{Name: "errors", Scope: domain.ThresholdScopeGlobal, Metric: domain.ThresholdMetricErrorRate,
+			Comparator: domain.ThresholdComparatorGTE, Value: 5, Severity: domain.ThresholdSeverityCritical},
+	} {
+		r.ID, r.TenantID = uuid.New(), tenantID
+		s.thresholds[r.ID] = r
+	}
+	f := domain.IngestionFilterRule{ID: uuid.New(), TenantID: tenantID, Name: "health checks", Field: "user",
+		Operator: domain.IngestionFilterEquals, Value: "monitor", Action: domain.IngestionFilterDrop, Enabled: true}
+	s.filters[f.ID] = f
+
+	hp := storage.DefaultHealthProfile()
+	hp.TenantID, hp.Version, hp.RedBelow = tenantID, len(s.profiles)+1, 40
+	s.profiles = append(s.profiles, *hp)
+
+	ss := domain.SavedSearch{ID: uuid.New(), TenantID: tenantID, UserID: "user-1", Name: "slow forms",
+		KQLQuery: "duration_ms:>1000", Filters: []byte(`{"log_type":["API"],"limit":50}`), IsPinned: true}
+	s.searches[ss.ID] = ss
+}
+
+func TestExport_Canonical(t *testing.T) {
+	s := newMemStore()
+	seed(s, tenantA)
+	m := NewManager(DefaultAdapters(s)...)
+
+	doc, err := m.Export(context.Background(), tenantA)
+	require.NoError(t, err)
+	out := string(doc)
+
+	assert.True(t, strings.HasPrefix(out, "api_version: remedyiq/v1\nkind: TenantConfig\nthreshold_rules:\n"), out)
+	assert.Less(t, strings.Index(out, "- name: errors"), strings.Index(out, "- name: slow api"), "resources are sorted by key")
+	assert.Less(t, strings.Index(out, "ingestion_filters:"), strings.Index(out, "health_profile:"))
+	assert.Contains(t, out, "red_below: 40")
+	assert.Contains(t, out, "owner: user-1")
+	assert.NotContains(t, out, "tenant_id")
+	assert.NotContains(t, out, "created_at")
+
+	again, err := m.Export(context.Background(), tenantA)
+	require.NoError(t, err)
+	assert.Equal(t, out, string(again))
+}
+
+func TestImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := newMemStore()
+	seed(src, tenantA)
+	exported, err := NewManager(DefaultAdapters(src)...).Export(ctx, tenantA)
+	require.NoError(t, err)
+
+	dst := newMemStore()
+	tenantB := uuid.New()
+	m := NewManager(DefaultAdapters(dst)...)
+	doc, err := m.Parse(exported)
+	require.NoError(t, err)
+	changes, err := m.Plan(ctx, tenantB, doc, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 5) // two rules, a filter, a saved search and the profile
+	res := m.Apply(WithActor(ctx, "admin-1"), tenantB, changes)
+	require.Nil(t, res.Failed)
+	assert.Len(t, res.Applied, 5)
+	assert.Equal(t, "admin-1", dst.profiles[0].CreatedBy)
+
+	reexported, err := m.Export(ctx, tenantB)
+	require.NoError(t, err)
+	assert.Equal(t, string(exported), string(reexported))
+
+	// Applying the document again changes nothing.
+	changes, err = m.Plan(ctx, tenantB, doc, true)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+const editedDoc = `api_version: remedyiq/v1
+kind: TenantConfig
+threshold_rules:
+  - name: errors
+    scope: global
+    metric: error_rate
+    comparator: gte
+    value: 10
+    severity: critical
+    enabled: false
+  - name: queue gaps
+    scope: queue
+    scope_value: Fast
+    metric: max_gap
+    comparator: gt
+    value: 30000
+ingestion_filters:
+  - name: health checks
+    field: user
+    operator: equals
+    value: monitor
+    case_sensitive: false
+    action: drop
+    enabled: true
+`
+
+func TestPlan_DryRunDiff(t *testing.T) {
+	ctx := context.Background()
+	s := newMemStore()
+	seed(s, tenantA)
+	m := NewManager(DefaultAdapters(s)...)
+	doc, err := m.Parse([]byte(editedDoc))
+	require.NoError(t, err)
+
+	summary := func(changes []Change) []string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, fmt.Sprintf("%s %s %s", c.Action, c.Section, c.Key))
+		}
+		return out
+	}
+
+	changes, err := m.Plan(ctx, tenantA, doc, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"update threshold_rules errors",
+		"create threshold_rules queue gaps",
+	}, summary(changes))
+	before := changes[0].Before.(thresholdRuleSpec)
+	after := changes[0].After.(thresholdRuleSpec)
+	assert.Equal(t, 5.0, before.Value)
+	assert.Equal(t, 10.0, after.Value)
+	assert.Equal(t, domain.ThresholdSeverityWarning, changes[1].After.(thresholdRuleSpec).Severity, "defaults are filled in")
+
+	changes, err = m.Plan(ctx, tenantA, doc, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"update threshold_rules errors",
+		"create threshold_rules queue gaps",
+		"delete threshold_rules slow api",
+		"delete health_profile health_profile",
+		"delete saved_searches user-1/slow forms",
+	}, summary(changes))
+
+	// Planning stores nothing.
+	assert.Len(t, s.thresholds, 2)
+	assert.Len(t, s.profiles, 1)
+}
+
+func TestApply_RollsBackFailedSection(t *testing.T) {
+	ctx := context.Background()
+	s := newMemStore()
+	seed(s, tenantA)
+	m := NewManager(DefaultAdapters(s)...)
+
+	doc, err := m.Parse([]byte(editedDoc + `  - name: drop debug
+    field: operation
+    operator: prefix
+    value: DEBUG
+  - name: flag demo
+    field: user
+    operator: equals
+    value: Demo
+    action: flag
+saved_searches: []
+`))
+	require.NoError(t, err)
+	changes, err := m.Plan(ctx, tenantA, doc, true)
+	require.NoError(t, err)
+
+	// The second new ingestion filter fails: the first is removed again,
+	// the threshold rules stay applied and the later sections are not
+	// touched.
+	s.fail["CreateIngestionFilterRule"] = 2
+	res := m.Apply(ctx, tenantA, changes)
+	require.NotNil(t, res.Failed)
+	assert.Equal(t, "ingestion_filters", res.Failed.Section)
+	assert.Equal(t, "flag demo", res.Failed.Key)
+	assert.Contains(t, res.Failed.Error, "connection reset")
+	require.Len(t, res.Failed.RolledBack, 1)
+	assert.Equal(t, "drop debug", res.Failed.RolledBack[0].Key)
+	assert.Empty(t, res.Failed.RollbackErrors)
+
+	var applied []string
+	for _, c := range res.Applied {
+		applied = append(applied, c.Section+" "+c.Key)
+	}
+	assert.Equal(t, []string{"threshold_rules errors", "threshold_rules queue gaps", "threshold_rules slow api"}, applied)
+
+	names := func() []string {
+		var out []string
+		for _, f := range s.filters {
+			out = append(out, f.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"health checks"}, names())
+	assert.Len(t, s.thresholds, 2)
+	assert.Len(t, s.profiles, 1, "health profile not reset")
+	assert.Len(t, s.searches, 1, "saved search not pruned")
+}
+
+func TestApply_RollsBackUpdatesAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	s := newMemStore()
+	seed(s, tenantA)
+	m := NewManager(DefaultAdapters(s)...)
+	orig := make(map[string]domain.ThresholdRule)
+	for _, r := range s.thresholds {
+		orig[r.Name] = r
+	}
+
+	doc, err := m.Parse([]byte(editedDoc))
+	require.NoError(t, err)
+	changes, err := m.Plan(ctx, tenantA, doc, true)
+	require.NoError(t, err)
+
+	// The threshold rule delete comes last in its section and fails.
+	s.fail["DeleteThresholdRule"] = 1
+	res := m.Apply(ctx, tenantA, changes)
+	require.NotNil(t, res.Failed)
+	assert.Equal(t, "threshold_rules", res.Failed.Section)
+	assert.Len(t, res.Failed.RolledBack, 2)
+	assert.Empty(t, res.Applied)
+
+	require.Len(t, s.thresholds, 2)
+	for _, r := range s.thresholds {
+		want := orig[r.Name]
+		assert.Equal(t, want.ID, r.ID)
+		assert.Equal(t, want.Value, r.Value)
+		assert.Equal(t, want.Enabled, r.Enabled)
+	}
+}
+
+func TestParse_ValidationErrors(t *testing.T) {
+	m := NewManager(DefaultAdapters(newMemStore())...)
+
+	tests := []struct {
+		name string
+		doc  string
+		want []ValidationError
+	}{
+		{
+			name: "syntax",
+			doc:  "api_version: remedyiq/v1\nkind: [\n",
+			want: []ValidationError{{Path: "$", Line: 2, Message: "did not find expected node content"}},
+		},
+		{
+			name: "header",
+			doc:  "api_version: remedyiq/v2\nwebhooks: []\n",
+			want: []ValidationError{
+				{Path: "api_version", Line: 1, Column: 14, Message: `unsupported api_version "remedyiq/v2"; expected remedyiq/v1`},
+				{Path: "webhooks", Line: 2, Column: 1, Message: "unknown section"},
+				{Path: "kind", Line: 1, Column: 1, Message: "kind is required"},
+			},
+		},
+		{
+			name: "fields",
+			doc: `api_version: remedyiq/v1
+kind: TenantConfig
+threshold_rules:
+  - name: a
+    scope: global
+    metric: p95
+    comparator: gt
+    value: lots
+  - name: b
+    scope: global
+    metric: latency
+    comparator: gt
+    value: 1
+  - name: a
+    scope: global
+    metric: p95
+    comparator: gt
+    value: 1
+    severty: info
+  - name: c
+    scope: global
+    metric: p95
+    comparator: gt
+    value: 1
+  - name: c
+    scope: global
+    metric: avg
+    comparator: gt
+    value: 1
+health_profile:
+  factors:
+    - name: error_rate
+      weight: heavy
+saved_searches:
+  - owner: user-1
+    name: x
+`,
+			want: []ValidationError{
+				{Path: "threshold_rules[0].value", Line: 8, Column: 12, Message: "must be a number"},
+				{Path: "threshold_rules[1]", Line: 9, Column: 5, Message: "metric must be one of error_rate, p95, avg, max_gap"},
+				{Path: "threshold_rules[2].severty", Line: 19, Column: 5, Message: "unknown field"},
+				{Path: "threshold_rules[4]", Line: 25, Column: 5, Message: `"c" is given more than once`},
+				{Path: "health_profile.factors[0].weight", Line: 33, Column: 15, Message: "must be a number"},
+				{Path: "saved_searches[0].kql_query", Line: 35, Column: 5, Message: "kql_query is required"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Parse([]byte(tt.doc))
+			var verrs ValidationErrors
+			require.True(t, errors.As(err, &verrs), "got %v", err)
+			require.Len(t, verrs, len(tt.want), "%v", verrs)
+			for i, want := range tt.want {
+				assert.Equal(t, want.Path, verrs[i].Path)
+				assert.Equal(t, want.Line, verrs[i].Line, want.Path)
+				if want.Column > 0 {
+					assert.Equal(t, want.Column, verrs[i].Column, want.Path)
+				}
+				if want.Message != "" {
+					assert.Equal(t, want.Message, verrs[i].Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package tenantmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// ErrReadMismatch is returned when a read of the dual-read verification
+// answers differently on the two clusters.
+var ErrReadMismatch = errors.New("tenantmigrate: dual-read mismatch")
+
+// dualRead runs the reads of up to VerifyJobs jobs, picked at random, on
+// both clusters and compares them. It returns the number of jobs compared.
+func (m *Migrator) dualRead(ctx context.Context, tenantID string, jobs []string) (int, error) {
+	sample := append([]string(nil), jobs...)
+	rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	if len(sample) > m.opts.VerifyJobs {
+		sample = sample[:m.opts.VerifyJobs]
+	}
+
+	for i, jobID := range sample {
+		src, err := readJob(ctx, m.src, tenantID, jobID)
+		if err != nil {
+			return i, fmt.Errorf("tenantmigrate: dual read job %s: source: %w", jobID, err)
+		}
+		dst, err := readJob(ctx, m.dst, tenantID, jobID)
+		if err != nil {
+			return i, fmt.Errorf("tenantmigrate: dual read job %s: target: %w", jobID, err)
+		}
+		if name, ok := src.diff(dst); !ok {
+			return i, fmt.Errorf("%w: job %s: %s", ErrReadMismatch, jobID, name)
+		}
+	}
+	return len(sample), nil
+}
+
+// jobReads are the results of the reads of a job the dual-read
+// verification compares, reduced to what does not vary between runs on the
+// same rows: any() picks and float averages are left out.
+type jobReads struct {
+	entries    int64
+	start, end int64
+	aggregates map[string]aggregateTotals // section/group name
+	exceptions map[string]int64           // error code -> count
+}
+
+type aggregateTotals struct {
+	count, totalMS, minMS, maxMS, errors int64
+	traces                               int
+}
+
+func readJob(ctx context.Context, c Cluster, tenantID, jobID string) (*jobReads, error) {
+	r := &jobReads{aggregates: make(map[string]aggregateTotals), exceptions: make(map[string]int64)}
+
+	var err error
+	if r.entries, err = c.CountJobEntries(ctx, tenantID, jobID); err != nil {
+		return nil, err
+	}
+	tr, err := c.GetJobTimeRange(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	r.start, r.end = tr.Start.UnixMilli(), tr.End.UnixMilli()
+
+	agg, err := c.GetAggregates(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	for name, sec := range map[string]*domain.AggregateSection{
+		"api": agg.API, "api_by_client": agg.APIByClient, "api_by_client_ip": agg.APIByClientIP,
+		"sql": agg.SQL, "filter": agg.Filter,
+	} {
+		if sec == nil {
+			continue
+		}
+		for _, g := range sec.Groups {
+			r.aggregates[name+"/"+g.Name] = aggregateTotals{g.Count, g.TotalMS, g.MinMS, g.MaxMS, g.ErrorCount, g.UniqueTraces}
+		}
+	}
+
+	exc, err := c.GetExceptions(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exc.Exceptions {
+		r.exceptions[e.ErrorCode] = e.Count
+	}
+	return r, nil
+}
+
+// diff names the first read that differs from other, if any.
+func (r *jobReads) diff(other *jobReads) (string, bool) {
+	switch {
+	case r.entries != other.entries:
+		return fmt.Sprintf("entry count %d on the source, %d on the target", r.entries, other.entries), false
+	case r.start != other.start || r.end != other.end:
+		return "time range", false
+	}
+	for _, name := range sortedKeys(r.aggregates, other.aggregates) {
+		if r.aggregates[name] != other.aggregates[name] {
+			return "aggregates " + name, false
+		}
+	}
+	if !reflect.DeepEqual(r.exceptions, other.exceptions) {
+		return "exceptions", false
+	}
+	return "", true
+}
+
+func sortedKeys(a, b map[string]aggregateTotals) []string {
+	seen := make(map[string]bool, len(a))
+	var keys []string
+	for _, m := range []map[string]aggregateTotals{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,317 @@
+package tenantmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// fakeCluster holds the log_entries rows of one tenant as line -> value per
+// job.
+type fakeCluster struct {
+	jobs map[string]map[int64]string
+
+	dropOnInsert int   // rows dropped from each insert
+	entriesSkew  int64 // added to CountJobEntries
+	inserts      int
+	deletes      int
+	rollups      []string // jobs whose rollup was built
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{jobs: make(map[string]map[int64]string)}
+}
+
+func (c *fakeCluster) add(jobID string, first, last int64) {
+	if c.jobs[jobID] == nil {
+		c.jobs[jobID] = make(map[int64]string)
+	}
+	for line := first; line <= last; line++ {
+		c.jobs[jobID][line] = fmt.Sprintf("%s:%d", jobID, line)
+	}
+}
+
+func (c *fakeCluster) ListTenantJobIDs(_ context.Context, _, _ string) ([]string, error) {
+	var ids []string
+	for id, rows := range c.jobs {
+		if len(rows) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (c *fakeCluster) JobLineSpan(_ context.Context, _, _, jobID string) (int64, int64, bool, error) {
+	first, last, ok := int64(0), int64(0), false
+	for line := range c.jobs[jobID] {
+		if !ok || line < first {
+			first = line
+		}
+		if !ok || line > last {
+			last = line
+		}
+		ok = true
+	}
+	return first, last, ok, nil
+}
+
+func (c *fakeCluster) ChunkChecksum(_ context.Context, _, _, jobID string, start, end int64) (storage.ChunkChecksum, error) {
+	var sum storage.ChunkChecksum
+	for line, v := range c.jobs[jobID] {
+		if line >= start && line < end {
+			h := fnv.New64a()
+			h.Write([]byte(v))
+			sum.Rows++
+			sum.Sum += h.Sum64()
+		}
+	}
+	return sum, nil
+}
+
+func (c *fakeCluster) ReadChunk(_ context.Context, _, _, jobID string, start, end int64) (*storage.RowChunk, error) {
+	chunk := &storage.RowChunk{Columns: []string{"job_id", "line_number", "value"}}
+	for line, v := range c.jobs[jobID] {
+		if line >= start && line < end {
+			chunk.Rows = append(chunk.Rows, []any{jobID, line, v})
+		}
+	}
+	return chunk, nil
+}
+
+func (c *fakeCluster) InsertChunk(_ context.Context, _ string, chunk *storage.RowChunk) error {
+	c.inserts++
+	rows := chunk.Rows
+	if c.dropOnInsert > 0 && len(rows) > c.dropOnInsert {
+		rows = rows[c.dropOnInsert:]
+	}
+	for _, row := range rows {
+		jobID := row[0].(string)
+		if c.jobs[jobID] == nil {
+			c.jobs[jobID] = make(map[int64]string)
+		}
+		c.jobs[jobID][row[1].(int64)] = row[2].(string)
+	}
+	return nil
+}
+
+func (c *fakeCluster) DeleteChunk(_ context.Context, _, _, jobID string, start, end int64) error {
+	c.deletes++
+	for line := range c.jobs[jobID] {
+		if line >= start && line < end {
+			delete(c.jobs[jobID], line)
+		}
+	}
+	return nil
+}
+
+func (c *fakeCluster) BuildJobRollup(_ context.Context, _, jobID string) error {
+	c.rollups = append(c.rollups, jobID)
+	return nil
+}
+
+func (c *fakeCluster) CountJobEntries(_ context.Context, _, jobID string) (int64, error) {
+	return int64(len(c.jobs[jobID])) + c.entriesSkew, nil
+}
+
+func (c *fakeCluster) GetJobTimeRange(_ context.Context, _, jobID string) (*storage.JobTimeRange, error) {
+	first, last, _, _ := c.JobLineSpan(context.Background(), "", "", jobID)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &storage.JobTimeRange{Start: base.Add(time.Duration(first) * time.Second), End: base.Add(time.Duration(last) * time.Second)}, nil
+}
+
+func (c *fakeCluster) GetAggregates(_ context.Context, _, jobID string) (*domain.AggregatesResponse, error) {
+	return &domain.AggregatesResponse{API: &domain.AggregateSection{
+		Groups: []domain.AggregateGroup{{Name: "GET_ENTRY", Count: int64(len(c.jobs[jobID]))}},
+	}}, nil
+}
+
+func (c *fakeCluster) GetExceptions(_ context.Context, _, _ string) (*domain.ExceptionsResponse, error) {
+	return &domain.ExceptionsResponse{}, nil
+}
+
+// fakeStore keeps the migration state in memory; any other call fails the
+// embedded mock.
+type fakeStore struct {
+	*testutil.MockPostgresStore
+
+	migrations map[uuid.UUID]*domain.TenantMigration
+	chunks     map[uuid.UUID][]domain.TenantMigrationChunk
+	routes     map[uuid.UUID]string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		MockPostgresStore: new(testutil.MockPostgresStore),
+		migrations:        make(map[uuid.UUID]*domain.TenantMigration),
+		chunks:            make(map[uuid.UUID][]domain.TenantMigrationChunk),
+		routes:            make(map[uuid.UUID]string),
+	}
+}
+
+func (s *fakeStore) ListClickHouseRoutes(context.Context) (map[uuid.UUID]string, error) {
+	return s.routes, nil
+}
+
+func (s *fakeStore) SetClickHouseRoute(_ context.Context, tenantID uuid.UUID, cluster string) error {
+	s.routes[tenantID] = cluster
+	return nil
+}
+
+func (s *fakeStore) CreateTenantMigration(_ context.Context, m *domain.TenantMigration) error {
+	m.ID = uuid.New()
+	m.Status = domain.TenantMigrationRunning
+	cp := *m
+	s.migrations[m.ID] = &cp
+	return nil
+}
+
+func (s *fakeStore) GetUnfinishedTenantMigration(_ context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error) {
+	for _, m := range s.migrations {
+		if m.TenantID == tenantID && m.Status != domain.TenantMigrationCompleted {
+			cp := *m
+			return &cp, nil
+		}
+	}
+	return nil, fmt.Errorf("postgres: get unfinished tenant migration: not found")
+}
+
+func (s *fakeStore) UpdateTenantMigrationStatus(_ context.Context, id uuid.UUID, status domain.TenantMigrationStatus, errMsg string) error {
+	s.migrations[id].Status = status
+	s.migrations[id].Error = errMsg
+	return nil
+}
+
+func (s *fakeStore) RecordTenantMigrationChunk(_ context.Context, c *domain.TenantMigrationChunk) error {
+	s.chunks[c.MigrationID] = append(s.chunks[c.MigrationID], *c)
+	return nil
+}
+
+func (s *fakeStore) ListTenantMigrationChunks(_ context.Context, id uuid.UUID) ([]domain.TenantMigrationChunk, error) {
+	return s.chunks[id], nil
+}
+
+var tenant = uuid.MustParse("00000000-0000-0000-0000-0000000000aa")
+
+func sourceCluster() *fakeCluster {
+	src := newFakeCluster()
+	src.add("job-a", 1, 25)
+	src.add("job-b", 3, 12)
+	return src
+}
+
+func TestRun_CopiesVerifiesAndCutsOver(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+
+	res, err := New(pg, src, dst, Options{ChunkLines: 10, VerifyJobs: 2}).Run(context.Background(), tenant, "default", "eu")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, res.Jobs)
+	assert.Equal(t, 5, res.ChunksCopied) // job-a [0,10) [10,20) [20,30), job-b [0,10) [10,20)
+	assert.Equal(t, int64(35), res.RowsCopied)
+	assert.Equal(t, 2, res.JobsVerified)
+	assert.Equal(t, src.jobs, dst.jobs)
+	assert.ElementsMatch(t, []string{"job-a", "job-b"}, dst.rollups)
+	assert.Empty(t, src.rollups)
+	assert.Equal(t, "eu", pg.routes[tenant])
+	assert.Equal(t, domain.TenantMigrationCompleted, pg.migrations[res.MigrationID].Status)
+}
+
+func TestRun_ResumeSkipsVerifiedChunks(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+	mig := &domain.TenantMigration{TenantID: tenant, SourceCluster: "default", TargetCluster: "eu", ChunkLines: 10}
+	require.NoError(t, pg.CreateTenantMigration(context.Background(), mig))
+	pg.migrations[mig.ID].Status = domain.TenantMigrationFailed
+	dst.add("job-a", 1, 9)
+	pg.chunks[mig.ID] = []domain.TenantMigrationChunk{{MigrationID: mig.ID, TableName: "log_entries", JobID: "job-a", LineStart: 0}}
+
+	// A different chunk span would not line up with the recorded chunks,
+	// so the resumed migration keeps its own.
+	res, err := New(pg, src, dst, Options{ChunkLines: 7}).Run(context.Background(), tenant, "default", "eu")
+	require.NoError(t, err)
+
+	assert.Equal(t, mig.ID, res.MigrationID)
+	assert.Equal(t, 1, res.ChunksSkipped)
+	assert.Equal(t, 4, res.ChunksCopied)
+	assert.Equal(t, src.jobs, dst.jobs)
+	assert.Equal(t, "eu", pg.routes[tenant])
+}
+
+func TestRun_ReplacesPartialChunk(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+	dst.add("job-a", 10, 14) // an interrupted insert of [10,20)
+
+	res, err := New(pg, src, dst, Options{ChunkLines: 10}).Run(context.Background(), tenant, "default", "eu")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, dst.deletes)
+	assert.Equal(t, 5, res.ChunksCopied)
+	assert.Equal(t, src.jobs, dst.jobs)
+}
+
+func TestRun_ChecksumMismatchBlocksCutover(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+	dst.dropOnInsert = 1
+
+	res, err := New(pg, src, dst, Options{ChunkLines: 10}).Run(context.Background(), tenant, "default", "eu")
+	require.Error(t, err)
+
+	assert.True(t, errors.Is(err, ErrChecksumMismatch), err)
+	assert.Empty(t, pg.routes)
+	assert.Equal(t, domain.TenantMigrationFailed, pg.migrations[res.MigrationID].Status)
+	assert.Empty(t, pg.chunks[res.MigrationID])
+}
+
+func TestRun_StrayTargetJobBlocksCutover(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+	dst.add("job-z", 1, 3)
+
+	_, err := New(pg, src, dst, Options{ChunkLines: 10}).Run(context.Background(), tenant, "default", "eu")
+	require.Error(t, err)
+
+	assert.True(t, errors.Is(err, ErrChecksumMismatch), err)
+	assert.Empty(t, pg.routes)
+}
+
+func TestRun_DualReadMismatchBlocksCutover(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+	dst.entriesSkew = 1
+
+	res, err := New(pg, src, dst, Options{ChunkLines: 10, VerifyJobs: 1}).Run(context.Background(), tenant, "default", "eu")
+	require.Error(t, err)
+
+	assert.True(t, errors.Is(err, ErrReadMismatch), err)
+	assert.Empty(t, pg.routes)
+	assert.Equal(t, domain.TenantMigrationFailed, pg.migrations[res.MigrationID].Status)
+
+	// The copied chunks stay verified, so a rerun does not copy them again.
+	dst.entriesSkew = 0
+	res, err = New(pg, src, dst, Options{ChunkLines: 10, VerifyJobs: 1}).Run(context.Background(), tenant, "default", "eu")
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.ChunksCopied)
+	assert.Equal(t, 5, res.ChunksSkipped)
+}
+
+func TestRun_UnfinishedMigrationToOtherCluster(t *testing.T) {
+	pg, src, dst := newFakeStore(), sourceCluster(), newFakeCluster()
+	require.NoError(t, pg.CreateTenantMigration(context.Background(),
+		&domain.TenantMigration{TenantID: tenant, SourceCluster: "default", TargetCluster: "us", ChunkLines: 10}))
+
+	_, err := New(pg, src, dst, Options{}).Run(context.Background(), tenant, "default", "eu")
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "unfinished migration from default to us")
+	assert.Zero(t, dst.inserts)
+}
@@ -11,6 +11,7 @@ func LoadFixture(name string) ([]byte, error) {
 		filepath.Join("..", "..", "error_logs", name),
 		filepath.Join("testdata", name),
 		filepath.Join("..", "testdata", name),
+		filepath.Join("..", "..", "testdata", name),
 		filepath.Join("..", "..", "ARLogAnalyzer", "error_logs", name),
 	}
 
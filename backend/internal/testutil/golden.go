@@ -0,0 +1,140 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateGoldenEnv is the environment variable that makes AssertGolden
+// rewrite the golden files instead of comparing against them.
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// VolatileKeys are the JSON keys whose values depend on when a test ran or
+// on the machine it ran on rather than on its input: wall-clock timestamps
+// and resource usage.
+var VolatileKeys = []string{
+	"created_at",
+	"updated_at",
+	"started_at",
+	"completed_at",
+	"uploaded_at",
+	"ingested_at",
+	"deleted_at",
+	"expires_at",
+	"changed_at",
+	"generated_at",
+	"peak_rss_kb",
+	"cpu_time_ms",
+	"wall_time_ms",
+}
+
+var uuidRegex = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// NormalizeJSON rewrites a JSON document so that it compares equal across
+// runs. UUIDs, wherever they appear in strings, become <uuid-N> numbered in
+// the order they appear in the result, so that references between fields
+// are still checked.
+// The values of VolatileKeys and of the given extra keys, at any depth,
+// become "<volatile>". The result is indented with sorted object keys.
+func NormalizeJSON(data []byte, extraVolatileKeys ...string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("normalize json: %w", err)
+	}
+
+	n := &normalizer{
+		volatile: make(map[string]bool, len(VolatileKeys)+len(extraVolatileKeys)),
+		uuids:    make(map[string]string),
+	}
+	for _, k := range VolatileKeys {
+		n.volatile[k] = true
+	}
+	for _, k := range extraVolatileKeys {
+		n.volatile[k] = true
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(n.walk(doc)); err != nil {
+		return nil, fmt.Errorf("normalize json: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+type normalizer struct {
+	volatile map[string]bool
+	uuids    map[string]string
+}
+
+func (n *normalizer) walk(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// Walk the keys in order so that UUID numbering does not depend on
+		// map iteration.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if n.volatile[k] && v[k] != nil {
+				v[k] = "<volatile>"
+				continue
+			}
+			v[k] = n.walk(v[k])
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = n.walk(v[i])
+		}
+		return v
+	case string:
+		return uuidRegex.ReplaceAllStringFunc(v, func(id string) string {
+			key := strings.ToLower(id)
+			if p, ok := n.uuids[key]; ok {
+				return p
+			}
+			p := fmt.Sprintf("<uuid-%d>", len(n.uuids)+1)
+			n.uuids[key] = p
+			return p
+		})
+	default:
+		return v
+	}
+}
+
+// AssertGolden normalizes got with NormalizeJSON and compares it with the
+// golden file at path. With UPDATE_GOLDEN=1 in the environment the golden
+// file is rewritten instead, so that an intended change of a response is
+// recorded by running the test once more.
+func AssertGolden(t testing.TB, path string, got []byte, extraVolatileKeys ...string) {
+	t.Helper()
+
+	normalized, err := NormalizeJSON(got, extraVolatileKeys...)
+	require.NoError(t, err, "response for %s is not JSON: %s", filepath.Base(path), got)
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, normalized, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file missing; run with %s=1 to record it", UpdateGoldenEnv)
+	assert.Equal(t, string(want), string(normalized), "response differs from %s; run with %s=1 to re-record it", path, UpdateGoldenEnv)
+}
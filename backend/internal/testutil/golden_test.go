@@ -0,0 +1,65 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeJSON(t *testing.T) {
+	in := `{
+		"id": "8F14E45F-CEEA-467A-9575-3D4D8E2C6A11",
+		"file_id": "0b4c7d3e-2d51-4a8e-9f7c-5d0c1a2b3c4d",
+		"s3_key": "tenants/8f14e45f-ceea-467a-9575-3d4d8e2c6a11/file.log",
+		"created_at": "2026-10-15T08:00:00.123Z",
+		"log_start": "2025-11-24T14:46:58Z",
+		"deleted_at": null,
+		"entries": [{"entry_id": "5e0a8f6a-1d3b-4a8f-8d8a-0f2e6c1b9a77", "duration_ms": 12, "queue_time": 1.50}],
+		"seed": 42
+	}`
+
+	out, err := NormalizeJSON([]byte(in), "seed")
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "created_at": "<volatile>",
+  "deleted_at": null,
+  "entries": [
+    {
+      "duration_ms": 12,
+      "entry_id": "<uuid-1>",
+      "queue_time": 1.50
+    }
+  ],
+  "file_id": "<uuid-2>",
+  "id": "<uuid-3>",
+  "log_start": "2025-11-24T14:46:58Z",
+  "s3_key": "tenants/<uuid-3>/file.log",
+  "seed": "<volatile>"
+}
+`, string(out))
+
+	_, err = NormalizeJSON([]byte(`{"a":`))
+	assert.Error(t, err)
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden", "job.golden.json")
+	got := []byte(`{"id":"8f14e45f-ceea-467a-9575-3d4d8e2c6a11","updated_at":"2026-10-15T08:00:00Z","status":"complete"}`)
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	AssertGolden(t, path, got)
+	recorded, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(recorded), `"id": "<uuid-1>"`)
+
+	t.Setenv(UpdateGoldenEnv, "")
+	rerun := []byte(`{"id":"0b4c7d3e-2d51-4a8e-9f7c-5d0c1a2b3c4d","updated_at":"2026-10-16T09:30:00Z","status":"complete"}`)
+	AssertGolden(t, path, rerun)
+
+	inner := &testing.T{}
+	AssertGolden(inner, path, []byte(`{"id":"0b4c7d3e-2d51-4a8e-9f7c-5d0c1a2b3c4d","status":"failed"}`))
+	assert.True(t, inner.Failed())
+}
@@ -0,0 +1,87 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+)
+
+// JARCall records one run of a ReplayJARRunner.
+type JARCall struct {
+	FilePath string
+	Flags    domain.JARFlags
+	HeapMB   int
+}
+
+// ReplayJARRunner stands in for ARLogAnalyzer.jar: instead of analysing the
+// file it is given, it replays a recorded report, so that the pipeline can
+// run end to end without Java. It satisfies worker.JARRunner.
+type ReplayJARRunner struct {
+	// Reports holds the recorded report for each output format, keyed by
+	// domain.JAROutputXML, domain.JAROutputJSON or "" for the text report.
+	// A format without a report is answered with the text report, like a
+	// JAR that does not support structured output.
+	Reports map[string]string
+
+	mu    sync.Mutex
+	calls []JARCall
+}
+
+// NewReplayJARRunner returns a runner that replays the given text report.
+func NewReplayJARRunner(textReport string) *ReplayJARRunner {
+	return &ReplayJARRunner{Reports: map[string]string{"": textReport}}
+}
+
+// LoadReplayJARRunner returns a runner replaying the reports recorded as
+// the fixtures <name>.txt, <name>.xml and <name>.json. Only the text report
+// is required.
+func LoadReplayJARRunner(name string) (*ReplayJARRunner, error) {
+	text, err := LoadFixture(name + ".txt")
+	if err != nil {
+		return nil, err
+	}
+	r := NewReplayJARRunner(string(text))
+	for _, format := range []string{domain.JAROutputXML, domain.JAROutputJSON} {
+		if data, err := LoadFixture(name + "." + format); err == nil {
+			r.Reports[format] = string(data)
+		}
+	}
+	return r, nil
+}
+
+// Run replays the report for flags.OutputFormat, feeding its lines to
+// lineCallback as the JAR's output would be.
+func (r *ReplayJARRunner) Run(ctx context.Context, filePath string, flags domain.JARFlags, heapMB int, lineCallback func(string)) (*jar.Result, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, JARCall{FilePath: filePath, Flags: flags, HeapMB: heapMB})
+	r.mu.Unlock()
+
+	report, ok := r.Reports[flags.OutputFormat]
+	if !ok {
+		report, ok = r.Reports[""]
+	}
+	if !ok {
+		return nil, fmt.Errorf("replay jar: no recorded report")
+	}
+
+	if lineCallback != nil {
+		for _, line := range strings.Split(report, "\n") {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			lineCallback(line)
+		}
+	}
+	return &jar.Result{Stdout: report}, nil
+}
+
+// Calls returns the runs so far, oldest first.
+func (r *ReplayJARRunner) Calls() []JARCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]JARCall(nil), r.calls...)
+}
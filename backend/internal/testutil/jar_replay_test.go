@@ -0,0 +1,51 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestReplayJARRunner(t *testing.T) {
+	r := NewReplayJARRunner("line 1\nline 2")
+	r.Reports[domain.JAROutputXML] = "<report/>"
+
+	var lines []string
+	res, err := r.Run(context.Background(), "/tmp/a.log", domain.JARFlags{}, 1024, func(l string) { lines = append(lines, l) })
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2", res.Stdout)
+	assert.Zero(t, res.ExitCode)
+	assert.Equal(t, []string{"line 1", "line 2"}, lines)
+
+	res, err = r.Run(context.Background(), "/tmp/b.log", domain.JARFlags{OutputFormat: domain.JAROutputXML}, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<report/>", res.Stdout)
+
+	res, err = r.Run(context.Background(), "/tmp/c.log", domain.JARFlags{OutputFormat: domain.JAROutputJSON}, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2", res.Stdout, "formats without a recording get the text report")
+
+	calls := r.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, JARCall{FilePath: "/tmp/a.log", HeapMB: 1024}, calls[0])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.Run(ctx, "/tmp/d.log", domain.JARFlags{}, 0, func(string) {})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLoadReplayJARRunner(t *testing.T) {
+	r, err := LoadReplayJARRunner("jar_output_log1")
+	require.NoError(t, err)
+	assert.Contains(t, r.Reports, "")
+	assert.Contains(t, r.Reports, domain.JAROutputXML)
+	assert.Contains(t, r.Reports, domain.JAROutputJSON)
+
+	_, err = LoadReplayJARRunner("no_such_report")
+	assert.Error(t, err)
+}
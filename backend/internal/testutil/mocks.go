@@ -7,6 +7,7 @@ import (
 
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/ai"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/notify"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
 	"github.com/blevesearch/bleve/v2"
@@ -49,97 +50,946 @@ func (m *MockPostgresStore) GetTenantByClerkOrg(ctx context.Context, clerkOrgID
 	return args.Get(0).(*domain.Tenant), args.Error(1)
 }
 
+func (m *MockPostgresStore) ListTenants(ctx context.Context) ([]domain.Tenant, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Tenant), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateTenantRetentionClass(ctx context.Context, id uuid.UUID, class domain.RetentionClass) error {
+	args := m.Called(ctx, id, class)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) SetTenantRegion(ctx context.Context, id uuid.UUID, region string) error {
+	args := m.Called(ctx, id, region)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) SetTenantSQLRedaction(ctx context.Context, id uuid.UUID, policy domain.SQLRedaction) error {
+	args := m.Called(ctx, id, policy)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) TenantHasData(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListExpiredSandboxTenants(ctx context.Context, now time.Time) ([]domain.Tenant, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Tenant), args.Error(1)
+}
+
+func (m *MockPostgresStore) ConvertSandboxTenant(ctx context.Context, id uuid.UUID, now time.Time) (*domain.Tenant, error) {
+	args := m.Called(ctx, id, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Tenant), args.Error(1)
+}
+
+func (m *MockPostgresStore) ArchiveTenant(ctx context.Context, id uuid.UUID, now time.Time) ([]string, error) {
+	args := m.Called(ctx, id, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
 func (m *MockPostgresStore) CreateLogFile(ctx context.Context, f *domain.LogFile) error {
 	args := m.Called(ctx, f)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) GetLogFile(ctx context.Context, tenantID, fileID uuid.UUID) (*domain.LogFile, error) {
-	args := m.Called(ctx, tenantID, fileID)
+func (m *MockPostgresStore) GetLogFile(ctx context.Context, tenantID, fileID uuid.UUID) (*domain.LogFile, error) {
+	args := m.Called(ctx, tenantID, fileID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LogFile), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListLogFiles(ctx context.Context, tenantID uuid.UUID) ([]domain.LogFile, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.LogFile), args.Error(1)
+}
+
+func (m *MockPostgresStore) DeleteLogFile(ctx context.Context, tenantID, fileID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, fileID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateJob(ctx context.Context, job *domain.AnalysisJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AnalysisJob), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateJobStatus(ctx context.Context, tenantID, jobID uuid.UUID, status domain.JobStatus, errMsg *string) error {
+	args := m.Called(ctx, tenantID, jobID, status, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) RequestJobCancel(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, bool, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*domain.AnalysisJob), args.Bool(1), args.Error(2)
+}
+
+func (m *MockPostgresStore) UpdateJobProgress(ctx context.Context, tenantID, jobID uuid.UUID, progressPct int, processedLines *int64) error {
+	args := m.Called(ctx, tenantID, jobID, progressPct, processedLines)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobResources(ctx context.Context, tenantID, jobID uuid.UUID, usage domain.JobResourceUsage) error {
+	args := m.Called(ctx, tenantID, jobID, usage)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobLogFormat(ctx context.Context, tenantID, jobID uuid.UUID, format domain.LogFormat) error {
+	args := m.Called(ctx, tenantID, jobID, format)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobClockSkew(ctx context.Context, tenantID, jobID uuid.UUID, report *domain.ClockSkewReport) error {
+	args := m.Called(ctx, tenantID, jobID, report)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobRestarts(ctx context.Context, tenantID, jobID uuid.UUID, restarts []domain.RestartEvent) error {
+	args := m.Called(ctx, tenantID, jobID, restarts)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobThreadCounts(ctx context.Context, tenantID, jobID uuid.UUID, counts map[string]int) error {
+	args := m.Called(ctx, tenantID, jobID, counts)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobSampling(ctx context.Context, tenantID, jobID uuid.UUID, sampling *domain.Sampling) error {
+	args := m.Called(ctx, tenantID, jobID, sampling)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobIngestionFilterStats(ctx context.Context, tenantID, jobID uuid.UUID, stats []domain.IngestionFilterMatch) error {
+	args := m.Called(ctx, tenantID, jobID, stats)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobSectionPresence(ctx context.Context, tenantID, jobID uuid.UUID, sections *domain.SectionPresence) error {
+	args := m.Called(ctx, tenantID, jobID, sections)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobParseDiagnostics(ctx context.Context, tenantID, jobID uuid.UUID, diag *domain.ParseDiagnostics) error {
+	args := m.Called(ctx, tenantID, jobID, diag)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobReconciliation(ctx context.Context, tenantID, jobID uuid.UUID, rec *domain.IngestionReconciliation) error {
+	args := m.Called(ctx, tenantID, jobID, rec)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobFileIntegrity(ctx context.Context, tenantID, jobID uuid.UUID, report *domain.FileIntegrity) error {
+	args := m.Called(ctx, tenantID, jobID, report)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobErrorOnset(ctx context.Context, tenantID, jobID uuid.UUID, onset *domain.ErrorOnset) error {
+	args := m.Called(ctx, tenantID, jobID, onset)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobFocusWindow(ctx context.Context, tenantID, jobID uuid.UUID, window *domain.FocusWindow) error {
+	args := m.Called(ctx, tenantID, jobID, window)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateJobCaptureDrift(ctx context.Context, tenantID, jobID uuid.UUID, profile *domain.CaptureProfile, drift *domain.CaptureDrift) error {
+	args := m.Called(ctx, tenantID, jobID, profile, drift)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetCaptureReference(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, string, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).(*domain.AnalysisJob), args.String(1), args.Error(2)
+}
+
+func (m *MockPostgresStore) SetCaptureBaseline(ctx context.Context, tenantID, jobID uuid.UUID, baseline bool) error {
+	args := m.Called(ctx, tenantID, jobID, baseline)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetJobErrorOnset(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.ErrorOnset, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ErrorOnset), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateJobAPILegend(ctx context.Context, tenantID, jobID uuid.UUID, legend []domain.JARAPIAbbreviation) error {
+	args := m.Called(ctx, tenantID, jobID, legend)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetJobAPILegend(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JARAPIAbbreviation, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.JARAPIAbbreviation), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnalysisJob), args.Error(1)
+}
+
+func (m *MockPostgresStore) CountJobs(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	args := m.Called(ctx, tenantID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListJobsFiltered(ctx context.Context, tenantID uuid.UUID, filter domain.JobListFilter) ([]domain.AnalysisJob, error) {
+	args := m.Called(ctx, tenantID, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnalysisJob), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateJobInvestigation(ctx context.Context, tenantID, jobID uuid.UUID, expectedVersion int, update domain.InvestigationUpdate, actorID string) (*domain.Investigation, *domain.InvestigationEvent, error) {
+	args := m.Called(ctx, tenantID, jobID, expectedVersion, update, actorID)
+	var inv *domain.Investigation
+	if v := args.Get(0); v != nil {
+		inv = v.(*domain.Investigation)
+	}
+	var ev *domain.InvestigationEvent
+	if v := args.Get(1); v != nil {
+		ev = v.(*domain.InvestigationEvent)
+	}
+	return inv, ev, args.Error(2)
+}
+
+func (m *MockPostgresStore) CreateSupportGrant(ctx context.Context, g *domain.SupportGrant) error {
+	args := m.Called(ctx, g)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListSupportGrants(ctx context.Context, tenantID uuid.UUID) ([]domain.SupportGrant, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SupportGrant), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetActiveSupportGrant(ctx context.Context, tenantID uuid.UUID) (*domain.SupportGrant, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SupportGrant), args.Error(1)
+}
+
+func (m *MockPostgresStore) RevokeSupportGrant(ctx context.Context, tenantID, grantID uuid.UUID, revokedBy string) error {
+	args := m.Called(ctx, tenantID, grantID, revokedBy)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) RecordSupportAccess(ctx context.Context, ev *domain.SupportAccessEvent) error {
+	args := m.Called(ctx, ev)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListSupportAccessEvents(ctx context.Context, tenantID uuid.UUID, limit int) ([]domain.SupportAccessEvent, error) {
+	args := m.Called(ctx, tenantID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SupportAccessEvent), args.Error(1)
+}
+
+func (m *MockPostgresStore) SoftDeleteJob(ctx context.Context, tenantID, jobID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, jobID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) RestoreJob(ctx context.Context, tenantID, jobID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, jobID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListDeletedJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnalysisJob), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListPurgeableJobs(ctx context.Context, before time.Time, limit int) ([]domain.AnalysisJob, error) {
+	args := m.Called(ctx, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnalysisJob), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetJobQueueSnapshot(ctx context.Context) (*domain.JobQueueSnapshot, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.JobQueueSnapshot), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetJobCostModel(ctx context.Context, tenantID uuid.UUID, sourceType domain.LogSourceType) (*domain.JobCostModel, error) {
+	args := m.Called(ctx, tenantID, sourceType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.JobCostModel), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpsertJobCostModel(ctx context.Context, model *domain.JobCostModel) error {
+	args := m.Called(ctx, model)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListJobCostSamples(ctx context.Context, perModel int) ([]domain.JobCostSample, error) {
+	args := m.Called(ctx, perModel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.JobCostSample), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateJobEstimateAccuracy(ctx context.Context, tenantID, jobID uuid.UUID, acc *domain.EstimateAccuracy) error {
+	args := m.Called(ctx, tenantID, jobID, acc)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetQueueActivity(ctx context.Context, finishedSince, workersSince time.Time) (*domain.QueueActivity, error) {
+	args := m.Called(ctx, finishedSince, workersSince)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.QueueActivity), args.Error(1)
+}
+
+func (m *MockPostgresStore) RecordWorkerHeartbeat(ctx context.Context, hb *domain.WorkerHeartbeat) error {
+	args := m.Called(ctx, hb)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DeleteWorkerHeartbeat(ctx context.Context, workerID string) error {
+	args := m.Called(ctx, workerID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) PurgeJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.PurgedJob, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PurgedJob), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListInvestigationEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.InvestigationEvent, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.InvestigationEvent), args.Error(1)
+}
+
+func (m *MockPostgresStore) CreateAIInteraction(ctx context.Context, ai *domain.AIInteraction) error {
+	args := m.Called(ctx, ai)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateAIInteraction(ctx context.Context, tenantID, aiID uuid.UUID, outputText *string, tokensUsed *int, latencyMS *int, status string) error {
+	args := m.Called(ctx, tenantID, aiID, outputText, tokensUsed, latencyMS, status)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateSavedSearch(ctx context.Context, search *domain.SavedSearch) error {
+	args := m.Called(ctx, search)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListSavedSearches(ctx context.Context, tenantID uuid.UUID, userID string) ([]domain.SavedSearch, error) {
+	args := m.Called(ctx, tenantID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SavedSearch), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListTenantSavedSearches(ctx context.Context, tenantID uuid.UUID) ([]domain.SavedSearch, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SavedSearch), args.Error(1)
+}
+
+func (m *MockPostgresStore) DeleteSavedSearch(ctx context.Context, tenantID uuid.UUID, userID string, searchID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, userID, searchID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) RecordSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, jobID *uuid.UUID, kqlQuery string, resultCount int) error {
+	args := m.Called(ctx, tenantID, userID, jobID, kqlQuery, resultCount)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, limit int) ([]domain.SearchHistoryEntry, error) {
+	args := m.Called(ctx, tenantID, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SearchHistoryEntry), args.Error(1)
+}
+
+func (m *MockPostgresStore) CreateSearchExport(ctx context.Context, e *domain.SearchExport) error {
+	args := m.Called(ctx, e)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetSearchExport(ctx context.Context, tenantID, exportID uuid.UUID) (*domain.SearchExport, error) {
+	args := m.Called(ctx, tenantID, exportID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SearchExport), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListSearchExports(ctx context.Context, tenantID uuid.UUID, limit int) ([]domain.SearchExport, error) {
+	args := m.Called(ctx, tenantID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SearchExport), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListExpiredSearchExports(ctx context.Context, before time.Time, limit int) ([]domain.SearchExport, error) {
+	args := m.Called(ctx, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SearchExport), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateSearchExportStatus(ctx context.Context, tenantID, exportID uuid.UUID, status domain.ExportStatus, errMsg *string) error {
+	args := m.Called(ctx, tenantID, exportID, status, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) UpdateSearchExportProgress(ctx context.Context, tenantID, exportID uuid.UUID, progressPct int, rowCount int64) error {
+	args := m.Called(ctx, tenantID, exportID, progressPct, rowCount)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CompleteSearchExport(ctx context.Context, tenantID, exportID uuid.UUID, s3Key string, rowCount, sizeBytes int64, prov *domain.ArtifactProvenance) error {
+	args := m.Called(ctx, tenantID, exportID, s3Key, rowCount, sizeBytes, prov)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateThresholdRule(ctx context.Context, rule *domain.ThresholdRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetThresholdRule(ctx context.Context, tenantID, ruleID uuid.UUID) (*domain.ThresholdRule, error) {
+	args := m.Called(ctx, tenantID, ruleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ThresholdRule), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListThresholdRules(ctx context.Context, tenantID uuid.UUID) ([]domain.ThresholdRule, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ThresholdRule), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateThresholdRule(ctx context.Context, rule *domain.ThresholdRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DeleteThresholdRule(ctx context.Context, tenantID, ruleID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, ruleID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ReplaceJobViolations(ctx context.Context, tenantID, jobID uuid.UUID, violations []domain.ThresholdViolation) error {
+	args := m.Called(ctx, tenantID, jobID, violations)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListJobViolations(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.ThresholdViolation, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ThresholdViolation), args.Error(1)
+}
+
+func (m *MockPostgresStore) CreateIngestionFilterRule(ctx context.Context, rule *domain.IngestionFilterRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetIngestionFilterRule(ctx context.Context, tenantID, ruleID uuid.UUID) (*domain.IngestionFilterRule, error) {
+	args := m.Called(ctx, tenantID, ruleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionFilterRule), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListIngestionFilterRules(ctx context.Context, tenantID uuid.UUID) ([]domain.IngestionFilterRule, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.IngestionFilterRule), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateIngestionFilterRule(ctx context.Context, rule *domain.IngestionFilterRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DeleteIngestionFilterRule(ctx context.Context, tenantID, ruleID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, ruleID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateAnalysisLink(ctx context.Context, link *domain.AnalysisLink) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListAnalysisLinks(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID) ([]domain.AnalysisLink, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnalysisLink), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListAnalysisLinksByJobs(ctx context.Context, tenantID uuid.UUID, jobIDs []uuid.UUID) (map[uuid.UUID][]domain.AnalysisLink, error) {
+	args := m.Called(ctx, tenantID, jobIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID][]domain.AnalysisLink), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListAnalysisLinksToSync(ctx context.Context, staleBefore time.Time, limit int) ([]domain.AnalysisLink, error) {
+	args := m.Called(ctx, staleBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnalysisLink), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateAnalysisLinkStatus(ctx context.Context, tenantID uuid.UUID, linkID uuid.UUID, status, summary string, syncedAt time.Time) error {
+	args := m.Called(ctx, tenantID, linkID, status, summary, syncedAt)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DeleteAnalysisLink(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, linkID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, jobID, linkID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateIncidentGroup(ctx context.Context, g *domain.IncidentGroup, jobIDs []uuid.UUID) error {
+	args := m.Called(ctx, g, jobIDs)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetIncidentGroup(ctx context.Context, tenantID uuid.UUID, groupID uuid.UUID) (*domain.IncidentGroup, error) {
+	args := m.Called(ctx, tenantID, groupID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IncidentGroup), args.Error(1)
+}
+
+func (m *MockPostgresStore) SetJobIncidentGroup(ctx context.Context, tenantID uuid.UUID, jobID uuid.UUID, groupID *uuid.UUID) error {
+	args := m.Called(ctx, tenantID, jobID, groupID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetTicketingIntegration(ctx context.Context, tenantID uuid.UUID, t domain.AnalysisLinkType) (*domain.TicketingIntegration, error) {
+	args := m.Called(ctx, tenantID, t)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TicketingIntegration), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpsertTicketingIntegration(ctx context.Context, ti *domain.TicketingIntegration) error {
+	args := m.Called(ctx, ti)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DeleteTicketingIntegration(ctx context.Context, tenantID uuid.UUID, t domain.AnalysisLinkType) error {
+	args := m.Called(ctx, tenantID, t)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateLocalUser(ctx context.Context, u *domain.LocalUser) error {
+	args := m.Called(ctx, u)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetLocalUserByEmail(ctx context.Context, email string) (*domain.LocalUser, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LocalUser), args.Error(1)
+}
+
+func (m *MockPostgresStore) CountLocalUsers(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostgresStore) RecordLocalLogin(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, userID, at)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListClickHouseRoutes(ctx context.Context) (map[uuid.UUID]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]string), args.Error(1)
+}
+
+func (m *MockPostgresStore) SetClickHouseRoute(ctx context.Context, tenantID uuid.UUID, cluster string) error {
+	args := m.Called(ctx, tenantID, cluster)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) CreateTenantMigration(ctx context.Context, tm *domain.TenantMigration) error {
+	args := m.Called(ctx, tm)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetUnfinishedTenantMigration(ctx context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TenantMigration), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetLastCompletedTenantMigration(ctx context.Context, tenantID uuid.UUID) (*domain.TenantMigration, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TenantMigration), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpdateTenantMigrationStatus(ctx context.Context, id uuid.UUID, status domain.TenantMigrationStatus, errMsg string) error {
+	args := m.Called(ctx, id, status, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) RecordTenantMigrationChunk(ctx context.Context, c *domain.TenantMigrationChunk) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListTenantMigrationChunks(ctx context.Context, migrationID uuid.UUID) ([]domain.TenantMigrationChunk, error) {
+	args := m.Called(ctx, migrationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.TenantMigrationChunk), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetDigestSubscription(ctx context.Context, tenantID uuid.UUID) (*domain.DigestSubscription, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DigestSubscription), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpsertDigestSubscription(ctx context.Context, s *domain.DigestSubscription) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListDigestSubscriptions(ctx context.Context) ([]domain.DigestSubscription, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.DigestSubscription), args.Error(1)
+}
+
+func (m *MockPostgresStore) MarkDigestSent(ctx context.Context, tenantID uuid.UUID, periodEnd time.Time) error {
+	args := m.Called(ctx, tenantID, periodEnd)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) GetHealthProfile(ctx context.Context, tenantID uuid.UUID) (*domain.HealthProfile, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.HealthProfile), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetHealthProfileVersion(ctx context.Context, tenantID uuid.UUID, version int) (*domain.HealthProfile, error) {
+	args := m.Called(ctx, tenantID, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.HealthProfile), args.Error(1)
+}
+
+func (m *MockPostgresStore) CreateHealthProfile(ctx context.Context, hp *domain.HealthProfile, expectedVersion int) error {
+	args := m.Called(ctx, hp, expectedVersion)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) BeginIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, bool, error) {
+	args := m.Called(ctx, rec)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*domain.IdempotencyRecord), args.Bool(1), args.Error(2)
+}
+
+func (m *MockPostgresStore) CompleteIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ReleaseIdempotentRequest(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPostgresStore) RegisterBackgroundTask(ctx context.Context, task *domain.BackgroundTask) error {
+	args := m.Called(ctx, task)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) DueBackgroundTasks(ctx context.Context, now time.Time) ([]string, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockPostgresStore) ClaimBackgroundTask(ctx context.Context, name, workerID string, now, lockedUntil, nextRunAt time.Time) (string, bool, error) {
+	args := m.Called(ctx, name, workerID, now, lockedUntil, nextRunAt)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockPostgresStore) FinishBackgroundTaskRun(ctx context.Context, run *domain.BackgroundTaskRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockPostgresStore) ListBackgroundTasks(ctx context.Context) ([]domain.BackgroundTask, error) {
+	args := m.Called(ctx)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*domain.LogFile), args.Error(1)
+	return args.Get(0).([]domain.BackgroundTask), args.Error(1)
 }
 
-func (m *MockPostgresStore) ListLogFiles(ctx context.Context, tenantID uuid.UUID) ([]domain.LogFile, error) {
-	args := m.Called(ctx, tenantID)
+func (m *MockPostgresStore) RequestBackgroundTaskRun(ctx context.Context, name, requestedBy string) (*domain.BackgroundTask, error) {
+	args := m.Called(ctx, name, requestedBy)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]domain.LogFile), args.Error(1)
+	return args.Get(0).(*domain.BackgroundTask), args.Error(1)
 }
 
-func (m *MockPostgresStore) CreateJob(ctx context.Context, job *domain.AnalysisJob) error {
-	args := m.Called(ctx, job)
+func (m *MockPostgresStore) GetServerSettings(ctx context.Context) (*domain.ServerSettings, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ServerSettings), args.Error(1)
+}
+
+func (m *MockPostgresStore) CreateServerSettings(ctx context.Context, s *domain.ServerSettings, expectedVersion int) error {
+	args := m.Called(ctx, s, expectedVersion)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.AnalysisJob, error) {
-	args := m.Called(ctx, tenantID, jobID)
+func (m *MockPostgresStore) GetPreferences(ctx context.Context, tenantID uuid.UUID, userID, namespace string) (*domain.Preferences, error) {
+	args := m.Called(ctx, tenantID, userID, namespace)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*domain.AnalysisJob), args.Error(1)
+	return args.Get(0).(*domain.Preferences), args.Error(1)
 }
 
-func (m *MockPostgresStore) UpdateJobStatus(ctx context.Context, tenantID, jobID uuid.UUID, status domain.JobStatus, errMsg *string) error {
-	args := m.Called(ctx, tenantID, jobID, status, errMsg)
+func (m *MockPostgresStore) PutPreferences(ctx context.Context, prefs *domain.Preferences, expectedRevision int) error {
+	args := m.Called(ctx, prefs, expectedRevision)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) UpdateJobProgress(ctx context.Context, tenantID, jobID uuid.UUID, progressPct int, processedLines *int64) error {
-	args := m.Called(ctx, tenantID, jobID, progressPct, processedLines)
+func (m *MockPostgresStore) CreateWorkspace(ctx context.Context, ws *domain.Workspace) error {
+	args := m.Called(ctx, ws)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) ListJobs(ctx context.Context, tenantID uuid.UUID) ([]domain.AnalysisJob, error) {
-	args := m.Called(ctx, tenantID)
+func (m *MockPostgresStore) GetWorkspace(ctx context.Context, tenantID, jobID, workspaceID uuid.UUID) (*domain.Workspace, error) {
+	args := m.Called(ctx, tenantID, jobID, workspaceID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]domain.AnalysisJob), args.Error(1)
+	return args.Get(0).(*domain.Workspace), args.Error(1)
 }
 
-func (m *MockPostgresStore) CreateAIInteraction(ctx context.Context, ai *domain.AIInteraction) error {
-	args := m.Called(ctx, ai)
-	return args.Error(0)
+func (m *MockPostgresStore) ListWorkspaces(ctx context.Context, tenantID, jobID uuid.UUID, userID string) ([]domain.Workspace, error) {
+	args := m.Called(ctx, tenantID, jobID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Workspace), args.Error(1)
 }
 
-func (m *MockPostgresStore) UpdateAIInteraction(ctx context.Context, tenantID, aiID uuid.UUID, outputText *string, tokensUsed *int, latencyMS *int, status string) error {
-	args := m.Called(ctx, tenantID, aiID, outputText, tokensUsed, latencyMS, status)
+func (m *MockPostgresStore) UpdateWorkspace(ctx context.Context, ws *domain.Workspace, expectedVersion int) error {
+	args := m.Called(ctx, ws, expectedVersion)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) CreateSavedSearch(ctx context.Context, search *domain.SavedSearch) error {
-	args := m.Called(ctx, search)
+func (m *MockPostgresStore) DeleteWorkspace(ctx context.Context, tenantID, jobID, workspaceID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, jobID, workspaceID)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) ListSavedSearches(ctx context.Context, tenantID uuid.UUID, userID string) ([]domain.SavedSearch, error) {
-	args := m.Called(ctx, tenantID, userID)
+func (m *MockPostgresStore) AppendJobEvents(ctx context.Context, events []domain.JobEvent) (int, error) {
+	args := m.Called(ctx, events)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListJobEvents(ctx context.Context, tenantID, jobID uuid.UUID) ([]domain.JobEvent, error) {
+	args := m.Called(ctx, tenantID, jobID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]domain.SavedSearch), args.Error(1)
+	return args.Get(0).([]domain.JobEvent), args.Error(1)
 }
 
-func (m *MockPostgresStore) DeleteSavedSearch(ctx context.Context, tenantID uuid.UUID, userID string, searchID uuid.UUID) error {
-	args := m.Called(ctx, tenantID, userID, searchID)
+func (m *MockPostgresStore) GetJobCheckpoint(ctx context.Context, tenantID, jobID uuid.UUID) (*domain.JobCheckpoint, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.JobCheckpoint), args.Error(1)
+}
+
+func (m *MockPostgresStore) SaveJobCheckpoint(ctx context.Context, cp *domain.JobCheckpoint) error {
+	args := m.Called(ctx, cp)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) RecordSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, jobID *uuid.UUID, kqlQuery string, resultCount int) error {
-	args := m.Called(ctx, tenantID, userID, jobID, kqlQuery, resultCount)
+func (m *MockPostgresStore) DeleteJobCheckpoint(ctx context.Context, tenantID, jobID uuid.UUID) error {
+	args := m.Called(ctx, tenantID, jobID)
 	return args.Error(0)
 }
 
-func (m *MockPostgresStore) GetSearchHistory(ctx context.Context, tenantID uuid.UUID, userID string, limit int) ([]domain.SearchHistoryEntry, error) {
-	args := m.Called(ctx, tenantID, userID, limit)
+func (m *MockPostgresStore) RecordUsageEvents(ctx context.Context, events []domain.UsageEvent) (int, error) {
+	args := m.Called(ctx, events)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListUsageEvents(ctx context.Context, since time.Time) ([]domain.UsageEvent, error) {
+	args := m.Called(ctx, since)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]domain.SearchHistoryEntry), args.Error(1)
+	return args.Get(0).([]domain.UsageEvent), args.Error(1)
+}
+
+func (m *MockPostgresStore) ListUsageSources(ctx context.Context, since time.Time) ([]domain.UsageEvent, error) {
+	args := m.Called(ctx, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.UsageEvent), args.Error(1)
+}
+
+func (m *MockPostgresStore) GetTenantUsage(ctx context.Context, tenantID *uuid.UUID, from, to time.Time, includeSandbox bool) ([]domain.UsageMonth, error) {
+	args := m.Called(ctx, tenantID, from, to, includeSandbox)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.UsageMonth), args.Error(1)
+}
+
+func (m *MockPostgresStore) UpsertVocabulary(ctx context.Context, tenantID, jobID uuid.UUID, field string, values []domain.AutocompleteValue) (int, error) {
+	args := m.Called(ctx, tenantID, jobID, field, values)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostgresStore) EvictVocabulary(ctx context.Context, tenantID uuid.UUID, unseenSince time.Time, maxPerField int) (int64, error) {
+	args := m.Called(ctx, tenantID, unseenSince, maxPerField)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPostgresStore) SearchVocabulary(ctx context.Context, tenantID uuid.UUID, field, query string, prefixOnly bool, limit int) ([]domain.VocabularyTerm, error) {
+	args := m.Called(ctx, tenantID, field, query, prefixOnly, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.VocabularyTerm), args.Error(1)
 }
 
 func (m *MockPostgresStore) Close() {
@@ -160,6 +1010,16 @@ func (m *MockClickHouseStore) BatchInsertEntries(ctx context.Context, entries []
 	return args.Error(0)
 }
 
+func (m *MockClickHouseStore) ResetSampleWeights(ctx context.Context, tenantID, jobID string, windows []domain.HotWindow) error {
+	args := m.Called(ctx, tenantID, jobID, windows)
+	return args.Error(0)
+}
+
+func (m *MockClickHouseStore) BuildJobRollup(ctx context.Context, tenantID, jobID string) error {
+	args := m.Called(ctx, tenantID, jobID)
+	return args.Error(0)
+}
+
 func (m *MockClickHouseStore) GetLogEntry(ctx context.Context, tenantID, jobID, entryID string) (*domain.LogEntry, error) {
 	args := m.Called(ctx, tenantID, jobID, entryID)
 	if args.Get(0) == nil {
@@ -168,6 +1028,27 @@ func (m *MockClickHouseStore) GetLogEntry(ctx context.Context, tenantID, jobID,
 	return args.Get(0).(*domain.LogEntry), args.Error(1)
 }
 
+func (m *MockClickHouseStore) GetEntrySummaries(ctx context.Context, tenantID, jobID string, entryIDs []string) ([]domain.LogEntry, error) {
+	args := m.Called(ctx, tenantID, jobID, entryIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.LogEntry), args.Error(1)
+}
+
+func (m *MockClickHouseStore) ResolveEntryID(ctx context.Context, tenantID, jobID string, fileNumber uint16, lineNumber uint32) (string, error) {
+	args := m.Called(ctx, tenantID, jobID, fileNumber, lineNumber)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetThresholdMetrics(ctx context.Context, tenantID, jobID string, scope domain.ThresholdScope, scopeValue string) (*domain.ThresholdMetrics, error) {
+	args := m.Called(ctx, tenantID, jobID, scope, scopeValue)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ThresholdMetrics), args.Error(1)
+}
+
 func (m *MockClickHouseStore) GetDashboardData(ctx context.Context, tenantID, jobID string, topN int) (*domain.DashboardData, error) {
 	args := m.Called(ctx, tenantID, jobID, topN)
 	if args.Get(0) == nil {
@@ -176,8 +1057,8 @@ func (m *MockClickHouseStore) GetDashboardData(ctx context.Context, tenantID, jo
 	return args.Get(0).(*domain.DashboardData), args.Error(1)
 }
 
-func (m *MockClickHouseStore) ComputeHealthScore(ctx context.Context, tenantID, jobID string) (*domain.HealthScore, error) {
-	args := m.Called(ctx, tenantID, jobID)
+func (m *MockClickHouseStore) ComputeHealthScore(ctx context.Context, tenantID, jobID string, profile *domain.HealthProfile, restarts []domain.RestartEvent) (*domain.HealthScore, error) {
+	args := m.Called(ctx, tenantID, jobID, profile, restarts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -192,6 +1073,14 @@ func (m *MockClickHouseStore) GetAggregates(ctx context.Context, tenantID, jobID
 	return args.Get(0).(*domain.AggregatesResponse), args.Error(1)
 }
 
+func (m *MockClickHouseStore) GetAggregatesPage(ctx context.Context, tenantID, jobID string, page domain.AggregatePage) (*domain.AggregatesResponse, error) {
+	args := m.Called(ctx, tenantID, jobID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AggregatesResponse), args.Error(1)
+}
+
 func (m *MockClickHouseStore) GetExceptions(ctx context.Context, tenantID, jobID string) (*domain.ExceptionsResponse, error) {
 	args := m.Called(ctx, tenantID, jobID)
 	if args.Get(0) == nil {
@@ -200,6 +1089,14 @@ func (m *MockClickHouseStore) GetExceptions(ctx context.Context, tenantID, jobID
 	return args.Get(0).(*domain.ExceptionsResponse), args.Error(1)
 }
 
+func (m *MockClickHouseStore) GetErrorHeatmap(ctx context.Context, tenantID, jobID, bucket string, limit int) (*domain.ErrorHeatmapResponse, error) {
+	args := m.Called(ctx, tenantID, jobID, bucket, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ErrorHeatmapResponse), args.Error(1)
+}
+
 func (m *MockClickHouseStore) GetGaps(ctx context.Context, tenantID, jobID string) (*domain.GapsResponse, error) {
 	args := m.Called(ctx, tenantID, jobID)
 	if args.Get(0) == nil {
@@ -216,11 +1113,51 @@ func (m *MockClickHouseStore) GetThreadStats(ctx context.Context, tenantID, jobI
 	return args.Get(0).(*domain.ThreadStatsResponse), args.Error(1)
 }
 
-func (m *MockClickHouseStore) GetFilterComplexity(ctx context.Context, tenantID, jobID string) (*domain.FilterComplexityResponse, error) {
+func (m *MockClickHouseStore) GetQueueLoad(ctx context.Context, tenantID, jobID string) ([]domain.QueueLoad, error) {
 	args := m.Called(ctx, tenantID, jobID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
+	return args.Get(0).([]domain.QueueLoad), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetThreadTimeline(ctx context.Context, tenantID, jobID string, q domain.ThreadTimelineQuery) ([]domain.ThreadTimeline, error) {
+	args := m.Called(ctx, tenantID, jobID, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ThreadTimeline), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetErrorOnset(ctx context.Context, tenantID, jobID string) (*domain.ErrorOnset, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ErrorOnset), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetFocusMetrics(ctx context.Context, tenantID, jobID string, from, to time.Time) (*domain.FocusMetrics, error) {
+	args := m.Called(ctx, tenantID, jobID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.FocusMetrics), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetSQLTableDrilldown(ctx context.Context, tenantID, jobID, table string) (*domain.SQLTableDrilldown, error) {
+	args := m.Called(ctx, tenantID, jobID, table)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SQLTableDrilldown), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetFilterComplexity(ctx context.Context, tenantID, jobID string, detail bool) (*domain.FilterComplexityResponse, error) {
+	args := m.Called(ctx, tenantID, jobID, detail)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*domain.FilterComplexityResponse), args.Error(1)
 }
 
@@ -248,6 +1185,14 @@ func (m *MockClickHouseStore) GetEntryContext(ctx context.Context, tenantID, job
 	return args.Get(0).(*domain.ContextResponse), args.Error(1)
 }
 
+func (m *MockClickHouseStore) GetJobVocabulary(ctx context.Context, tenantID, jobID string, limit int) (map[string][]domain.AutocompleteValue, error) {
+	args := m.Called(ctx, tenantID, jobID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string][]domain.AutocompleteValue), args.Error(1)
+}
+
 func (m *MockClickHouseStore) GetAutocompleteValues(ctx context.Context, tenantID, jobID, field, prefix string, limit int) ([]domain.AutocompleteValue, error) {
 	args := m.Called(ctx, tenantID, jobID, field, prefix, limit)
 	if args.Get(0) == nil {
@@ -264,6 +1209,18 @@ func (m *MockClickHouseStore) GetTraceEntries(ctx context.Context, tenantID, job
 	return args.Get(0).([]domain.LogEntry), args.Error(1)
 }
 
+func (m *MockClickHouseStore) GetTraceEntriesStream(ctx context.Context, tenantID, jobID, traceID string, q storage.TraceQuery, fn func(domain.LogEntry) error) error {
+	args := m.Called(ctx, tenantID, jobID, traceID, q)
+	if entries, ok := args.Get(0).([]domain.LogEntry); ok {
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
 func (m *MockClickHouseStore) GetJobTimeRange(ctx context.Context, tenantID, jobID string) (*storage.JobTimeRange, error) {
 	args := m.Called(ctx, tenantID, jobID)
 	if args.Get(0) == nil {
@@ -280,6 +1237,14 @@ func (m *MockClickHouseStore) GetFacets(ctx context.Context, tenantID, jobID str
 	return args.Get(0).(map[string][]storage.FacetValue), args.Error(1)
 }
 
+func (m *MockClickHouseStore) GetRefinementStats(ctx context.Context, tenantID, jobID string, q storage.SearchQuery) (*storage.RefinementStats, error) {
+	args := m.Called(ctx, tenantID, jobID, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.RefinementStats), args.Error(1)
+}
+
 func (m *MockClickHouseStore) SearchTransactions(ctx context.Context, tenantID, jobID string, params domain.TransactionSearchParams) (*domain.TransactionSearchResponse, error) {
 	args := m.Called(ctx, tenantID, jobID, params)
 	if args.Get(0) == nil {
@@ -296,6 +1261,68 @@ func (m *MockClickHouseStore) QueryDelayedEscalations(ctx context.Context, tenan
 	return args.Get(0).([]domain.DelayedEscalationEntry), args.Error(1)
 }
 
+func (m *MockClickHouseStore) EnsureRetentionTTL(ctx context.Context, tiering storage.TieringConfig) (bool, error) {
+	args := m.Called(ctx, tiering)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockClickHouseStore) UpdateTenantRetentionClass(ctx context.Context, tenantID string, class domain.RetentionClass) error {
+	args := m.Called(ctx, tenantID, class)
+	return args.Error(0)
+}
+
+func (m *MockClickHouseStore) GetTenantStorage(ctx context.Context) ([]domain.TenantStorage, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.TenantStorage), args.Error(1)
+}
+
+func (m *MockClickHouseStore) CountJobEntries(ctx context.Context, tenantID, jobID string) (int64, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockClickHouseStore) CountJobEntriesByType(ctx context.Context, tenantID, jobID string) (map[domain.LogType]int64, error) {
+	args := m.Called(ctx, tenantID, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[domain.LogType]int64), args.Error(1)
+}
+
+func (m *MockClickHouseStore) GetSourceOverlay(ctx context.Context, tenantID, jobID string, maxEvents int) (*domain.SourceOverlay, error) {
+	args := m.Called(ctx, tenantID, jobID, maxEvents)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SourceOverlay), args.Error(1)
+}
+
+func (m *MockClickHouseStore) CountIngestionFilterMatches(ctx context.Context, tenantID, jobID string, rule domain.IngestionFilterRule) (int64, error) {
+	args := m.Called(ctx, tenantID, jobID, rule)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockClickHouseStore) KillQuery(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockClickHouseStore) GetDurationPercentile(ctx context.Context, tenantID, jobID string, logType domain.LogType, scope, value string, durationMS uint32) (*domain.DurationPercentile, error) {
+	args := m.Called(ctx, tenantID, jobID, logType, scope, value, durationMS)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DurationPercentile), args.Error(1)
+}
+
+func (m *MockClickHouseStore) DeleteJobEntries(ctx context.Context, tenantID, jobID string) error {
+	args := m.Called(ctx, tenantID, jobID)
+	return args.Error(0)
+}
+
 func (m *MockClickHouseStore) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -335,16 +1362,58 @@ func (m *MockRedisCache) CheckRateLimit(ctx context.Context, key string, limit i
 	return args.Bool(0), args.Error(1)
 }
 
-type MockS3Storage struct {
+type MockCacheUsageStore struct {
+	mock.Mock
+}
+
+func (m *MockCacheUsageStore) TenantCacheUsage(ctx context.Context, tenantID string) (*domain.TenantCacheUsage, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TenantCacheUsage), args.Error(1)
+}
+
+func (m *MockCacheUsageStore) AllTenantsCacheUsage(ctx context.Context) ([]domain.TenantCacheUsage, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.TenantCacheUsage), args.Error(1)
+}
+
+type MockQuerySlotStore struct {
+	mock.Mock
+}
+
+func (m *MockQuerySlotStore) AcquireQuerySlot(ctx context.Context, q domain.InFlightQuery, limit int, lease time.Duration) (bool, error) {
+	args := m.Called(ctx, q, limit, lease)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockQuerySlotStore) ReleaseQuerySlot(ctx context.Context, tenantID, id string) error {
+	args := m.Called(ctx, tenantID, id)
+	return args.Error(0)
+}
+
+func (m *MockQuerySlotStore) ListQuerySlots(ctx context.Context, tenantID string) ([]domain.InFlightQuery, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.InFlightQuery), args.Error(1)
+}
+
+type MockObjectStorage struct {
 	mock.Mock
 }
 
-func (m *MockS3Storage) Upload(ctx context.Context, key string, reader io.Reader, size int64) error {
+func (m *MockObjectStorage) Upload(ctx context.Context, key string, reader io.Reader, size int64) error {
 	args := m.Called(ctx, key, reader, size)
 	return args.Error(0)
 }
 
-func (m *MockS3Storage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+func (m *MockObjectStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
 	args := m.Called(ctx, key)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -352,6 +1421,21 @@ func (m *MockS3Storage) Download(ctx context.Context, key string) (io.ReadCloser
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+func (m *MockObjectStorage) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockObjectStorage) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	args := m.Called(ctx, key, expiry)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockObjectStorage) Exists(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
 type MockAIClient struct {
 	mock.Mock
 }
@@ -408,11 +1492,36 @@ func (m *MockNATSStreamer) PublishJobProgress(ctx context.Context, tenantID stri
 	return args.Error(0)
 }
 
+func (m *MockNATSStreamer) PublishJobQueued(ctx context.Context, tenantID string, jobID string, queue domain.JobQueueEstimate) error {
+	args := m.Called(ctx, tenantID, jobID, queue)
+	return args.Error(0)
+}
+
 func (m *MockNATSStreamer) PublishJobComplete(ctx context.Context, tenantID string, jobID string, result domain.AnalysisJob) error {
 	args := m.Called(ctx, tenantID, jobID, result)
 	return args.Error(0)
 }
 
+func (m *MockNATSStreamer) PublishExportSubmit(ctx context.Context, tenantID string, export domain.SearchExport) error {
+	args := m.Called(ctx, tenantID, export)
+	return args.Error(0)
+}
+
+func (m *MockNATSStreamer) PublishThresholdViolation(ctx context.Context, tenantID string, violation domain.ThresholdViolation) error {
+	args := m.Called(ctx, tenantID, violation)
+	return args.Error(0)
+}
+
+func (m *MockNATSStreamer) PublishCaptureDrift(ctx context.Context, tenantID string, event domain.CaptureDriftEvent) error {
+	args := m.Called(ctx, tenantID, event)
+	return args.Error(0)
+}
+
+func (m *MockNATSStreamer) SubscribeAllExportSubmits(ctx context.Context, handler func(domain.SearchExport)) error {
+	args := m.Called(ctx, mock.AnythingOfType("func(domain.SearchExport)"))
+	return args.Error(0)
+}
+
 func (m *MockNATSStreamer) PublishLiveTailEntry(ctx context.Context, tenantID string, logType string, entry domain.LogEntry) error {
 	args := m.Called(ctx, tenantID, logType, entry)
 	return args.Error(0)
@@ -453,3 +1562,12 @@ func (m *MockSearchIndexer) Close() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Send(ctx context.Context, msg notify.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
@@ -0,0 +1,95 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// WSMessage is the envelope of the messages exchanged on the RemedyIQ
+// WebSocket, in both directions.
+type WSMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// WSClient is a WebSocket client for tests. It speaks the typed JSON
+// envelope of the streaming hub and fails the test on any I/O error.
+type WSClient struct {
+	t    testing.TB
+	conn *websocket.Conn
+
+	// Skipped holds the messages Expect read past while waiting for
+	// another type, oldest first.
+	Skipped []WSMessage
+}
+
+// DialWS opens a WebSocket to url, which may use the http or ws scheme,
+// sending header with the handshake. The connection is closed when the test
+// ends.
+func DialWS(t testing.TB, url string, header http.Header) *WSClient {
+	t.Helper()
+	if strings.HasPrefix(url, "http") {
+		url = "ws" + strings.TrimPrefix(url, "http")
+	}
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	require.NoError(t, err, "dial %s", url)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &WSClient{t: t, conn: conn}
+}
+
+// Send writes a message of the given type. A nil payload is omitted.
+func (c *WSClient) Send(msgType string, payload any) {
+	c.t.Helper()
+	msg := WSMessage{Type: msgType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		require.NoError(c.t, err)
+		msg.Payload = data
+	}
+	require.NoError(c.t, c.conn.WriteJSON(msg), "send %s", msgType)
+}
+
+// Expect reads messages until one of type msgType arrives and returns its
+// payload. Messages of other types are kept in Skipped; an error message
+// fails the test unless it is the one expected. The test also fails when
+// nothing matching arrives within timeout.
+func (c *WSClient) Expect(msgType string, timeout time.Duration) json.RawMessage {
+	c.t.Helper()
+	deadline := time.Now().Add(timeout)
+	require.NoError(c.t, c.conn.SetReadDeadline(deadline))
+	defer func() { _ = c.conn.SetReadDeadline(time.Time{}) }()
+
+	for {
+		var msg WSMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			require.FailNow(c.t, "no "+msgType+" message", "read: %v; skipped %d messages", err, len(c.Skipped))
+		}
+		if msg.Type == msgType {
+			return msg.Payload
+		}
+		if msg.Type == "error" {
+			require.FailNow(c.t, "unexpected error message", "waiting for %s: %s", msgType, msg.Payload)
+		}
+		c.Skipped = append(c.Skipped, msg)
+	}
+}
+
+// Sync sends a ping and waits for its pong. The hub handles the messages of
+// a connection in order, so once Sync returns the messages sent before it
+// have been acted on, such as subscriptions, which are not acknowledged.
+func (c *WSClient) Sync(timeout time.Duration) {
+	c.t.Helper()
+	c.Send("ping", nil)
+	c.Expect("pong", timeout)
+}
@@ -0,0 +1,59 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoHub answers ping with pong and echoes every other message twice, the
+// first time as a "notice" so that Expect has something to skip.
+func echoHub(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "tenant-1", r.Header.Get("X-Dev-Tenant-ID"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg WSMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type == "ping" {
+				_ = conn.WriteJSON(WSMessage{Type: "pong"})
+				continue
+			}
+			_ = conn.WriteJSON(WSMessage{Type: "notice"})
+			_ = conn.WriteJSON(msg)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWSClient(t *testing.T) {
+	srv := echoHub(t)
+	c := DialWS(t, srv.URL, http.Header{"X-Dev-Tenant-ID": {"tenant-1"}})
+
+	c.Sync(time.Second)
+
+	c.Send("investigation_updated", map[string]string{"job_id": "job-1"})
+	payload := c.Expect("investigation_updated", time.Second)
+	var got map[string]string
+	require.NoError(t, json.Unmarshal(payload, &got))
+	assert.Equal(t, "job-1", got["job_id"])
+	require.Len(t, c.Skipped, 1)
+	assert.Equal(t, "notice", c.Skipped[0].Type)
+
+	c.Send("error", map[string]string{"code": "UNKNOWN_TYPE"})
+	assert.JSONEq(t, `{"code":"UNKNOWN_TYPE"}`, string(c.Expect("error", time.Second)))
+}
@@ -0,0 +1,117 @@
+package ticketing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// basicClient calls the REST API of an instance, authenticating with the
+// username and token in basic auth, or the token alone as a bearer token.
+type basicClient struct {
+	baseURL  string
+	username string
+	token    string
+	http     *http.Client
+}
+
+// get decodes the JSON response to GET path?query into out. A 404 is
+// ErrNotFound.
+func (c basicClient) get(ctx context.Context, path string, query url.Values, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("ticketing: build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("ticketing: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("ticketing: credentials rejected (%d)", resp.StatusCode)
+	case resp.StatusCode/100 != 2:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ticketing: unexpected status %d: %s", resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("ticketing: decode response: %w", err)
+	}
+	return nil
+}
+
+// JiraClient looks issues up through the Jira REST API, version 2, which
+// both Jira Cloud and Data Center serve.
+type JiraClient struct {
+	basicClient
+}
+
+// Lookup returns the status and summary of the issue key.
+func (c *JiraClient) Lookup(ctx context.Context, key string) (*Record, error) {
+	var issue struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	err := c.get(ctx, "/rest/api/2/issue/"+url.PathEscape(key), url.Values{"fields": {"status,summary"}}, &issue)
+	if err != nil {
+		return nil, err
+	}
+	return &Record{Key: issue.Key, Status: issue.Fields.Status.Name, Summary: issue.Fields.Summary}, nil
+}
+
+// ServiceNowClient looks records up through the ServiceNow Table API. It
+// queries the task table, which incidents, problems, changes and requested
+// items all extend, so that any record number resolves.
+type ServiceNowClient struct {
+	basicClient
+}
+
+// Lookup returns the state and short description of the record number key.
+func (c *ServiceNowClient) Lookup(ctx context.Context, key string) (*Record, error) {
+	var resp struct {
+		Result []struct {
+			Number           string `json:"number"`
+			State            string `json:"state"`
+			ShortDescription string `json:"short_description"`
+		} `json:"result"`
+	}
+	err := c.get(ctx, "/api/now/table/task", url.Values{
+		"sysparm_query":         {"number=" + key},
+		"sysparm_fields":        {"number,state,short_description"},
+		"sysparm_display_value": {"true"},
+		"sysparm_limit":         {"1"},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Result) == 0 {
+		return nil, ErrNotFound
+	}
+	r := resp.Result[0]
+	return &Record{Key: r.Number, Status: r.State, Summary: r.ShortDescription}, nil
+}
@@ -0,0 +1,131 @@
+package ticketing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// envelopeVersion prefixes every sealed value, so that the layout can
+// change without breaking the values already stored.
+const envelopeVersion = 1
+
+// keyIDLen is the length of the key ID a sealed value names its key
+// encryption key by.
+const keyIDLen = 4
+
+// ErrWrongKey is returned when opening a value sealed under another key
+// encryption key than the keyring's.
+var ErrWrongKey = errors.New("ticketing: value sealed under another key")
+
+// Keyring seals secrets the way a KMS envelope does: every value is
+// encrypted with its own random data key, and the data key is encrypted
+// with the key encryption key from the configuration and stored alongside.
+// Rotating the key encryption key means rewrapping data keys only.
+//
+// A sealed value is the version byte, the key ID, the wrapped data key and
+// the encrypted value, both AES-256-GCM with their nonce first.
+type Keyring struct {
+	kek   cipher.AEAD
+	keyID [keyIDLen]byte
+}
+
+// NewKeyring returns the keyring of a base64-encoded 32-byte key
+// encryption key.
+func NewKeyring(encodedKey string) (*Keyring, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("ticketing: encryption key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("ticketing: encryption key must be 32 bytes, got %d", len(key))
+	}
+	kek, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{kek: kek}
+	sum := sha256.Sum256(key)
+	copy(k.keyID[:], sum[:])
+	return k, nil
+}
+
+// Seal encrypts plaintext under a new data key. aad binds the sealed value
+// to its context, e.g. the row it is stored in: Open fails with other aad.
+func (k *Keyring) Seal(plaintext, aad []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("ticketing: generate data key: %w", err)
+	}
+	wrapped, err := seal(k.kek, dataKey, k.keyID[:])
+	if err != nil {
+		return nil, err
+	}
+	dek, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dek, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 1+keyIDLen+len(wrapped)+len(ciphertext))
+	out = append(out, envelopeVersion)
+	out = append(out, k.keyID[:]...)
+	out = append(out, wrapped...)
+	return append(out, ciphertext...), nil
+}
+
+// Open decrypts a value Seal returned with the same aad.
+func (k *Keyring) Open(sealed, aad []byte) ([]byte, error) {
+	wrappedLen := k.kek.NonceSize() + 32 + k.kek.Overhead()
+	if len(sealed) < 1+keyIDLen+wrappedLen || sealed[0] != envelopeVersion {
+		return nil, errors.New("ticketing: malformed sealed value")
+	}
+	if string(sealed[1:1+keyIDLen]) != string(k.keyID[:]) {
+		return nil, ErrWrongKey
+	}
+	rest := sealed[1+keyIDLen:]
+	dataKey, err := open(k.kek, rest[:wrappedLen], k.keyID[:])
+	if err != nil {
+		return nil, fmt.Errorf("ticketing: unwrap data key: %w", err)
+	}
+	dek, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dek, rest[wrappedLen:], aad)
+	if err != nil {
+		return nil, fmt.Errorf("ticketing: decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("ticketing: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("ticketing: generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,127 @@
+// Package ticketing links analyses to the incidents they investigate in
+// Jira and ServiceNow: it validates external keys, looks records up through
+// a tenant's integration and seals the integration's API token.
+package ticketing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// requestTimeout bounds one lookup, so that an unreachable instance does
+// not hold a link request or the sync.
+const requestTimeout = 10 * time.Second
+
+// ErrNotFound is returned when the external system has no record with the
+// key looked up.
+var ErrNotFound = errors.New("ticketing: record not found")
+
+// Record is the state of an issue or record in the external system.
+type Record struct {
+	Key     string
+	Status  string
+	Summary string
+}
+
+// Client looks records up in one external system.
+type Client interface {
+	Lookup(ctx context.Context, key string) (*Record, error)
+}
+
+var (
+	jiraKeyPattern       = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-[0-9]+$`)
+	serviceNowKeyPattern = regexp.MustCompile(`^[A-Z]{2,8}[0-9]{4,}$`)
+)
+
+// NormalizeKey validates the external key of a link of type t, returning
+// it in canonical form: upper-case Jira keys (PROJ-1234) and ServiceNow
+// numbers (INC0012345), absolute http(s) URLs.
+func NormalizeKey(t domain.AnalysisLinkType, key string) (string, error) {
+	key = strings.TrimSpace(key)
+	switch t {
+	case domain.AnalysisLinkJira:
+		key = strings.ToUpper(key)
+		if !jiraKeyPattern.MatchString(key) {
+			return "", fmt.Errorf("invalid Jira issue key %q, expected e.g. PROJ-1234", key)
+		}
+	case domain.AnalysisLinkServiceNow:
+		key = strings.ToUpper(key)
+		if !serviceNowKeyPattern.MatchString(key) {
+			return "", fmt.Errorf("invalid ServiceNow number %q, expected e.g. INC0012345", key)
+		}
+	case domain.AnalysisLinkURL:
+		u, err := url.Parse(key)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("invalid URL %q, expected an http or https URL", key)
+		}
+	default:
+		return "", fmt.Errorf("type must be one of jira, servicenow, url")
+	}
+	return key, nil
+}
+
+// NormalizeBaseURL validates the base URL of an integration, returning it
+// without a trailing slash.
+func NormalizeBaseURL(base string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(base))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("base_url must be an http or https URL")
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// LinkURL returns where a link points: the URL of a url link, the record
+// in the tenant's instance at baseURL otherwise, empty without one.
+func LinkURL(t domain.AnalysisLinkType, baseURL, key string) string {
+	switch {
+	case t == domain.AnalysisLinkURL:
+		return key
+	case baseURL == "":
+		return ""
+	case t == domain.AnalysisLinkJira:
+		return baseURL + "/browse/" + url.PathEscape(key)
+	case t == domain.AnalysisLinkServiceNow:
+		return baseURL + "/nav_to.do?uri=" + url.QueryEscape("task.do?sysparm_query=number="+key)
+	}
+	return ""
+}
+
+// TokenAAD binds a sealed token to the tenant and type of its integration.
+func TokenAAD(tenantID uuid.UUID, t domain.AnalysisLinkType) []byte {
+	return []byte(tenantID.String() + "/" + string(t))
+}
+
+// NewClient returns the client of an integration, whose token keyring
+// opens. httpClient may be nil.
+func NewClient(integration *domain.TicketingIntegration, keyring *Keyring, httpClient *http.Client) (Client, error) {
+	token, err := keyring.Open(integration.Sealed, TokenAAD(integration.TenantID, integration.Type))
+	if err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+	base := basicClient{
+		baseURL:  integration.BaseURL,
+		username: integration.Username,
+		token:    string(token),
+		http:     httpClient,
+	}
+	switch integration.Type {
+	case domain.AnalysisLinkJira:
+		return &JiraClient{base}, nil
+	case domain.AnalysisLinkServiceNow:
+		return &ServiceNowClient{base}, nil
+	}
+	return nil, fmt.Errorf("ticketing: no client for %q", integration.Type)
+}
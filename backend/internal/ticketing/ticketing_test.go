@@ -0,0 +1,183 @@
+package ticketing
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func testKeyring(t *testing.T, fill byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32))))
+	require.NoError(t, err)
+	return k
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	k := testKeyring(t, 'k')
+	aad := []byte("tenant/jira")
+
+	sealed, err := k.Seal([]byte("s3cr3t-token"), aad)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "s3cr3t-token")
+
+	again, err := k.Seal([]byte("s3cr3t-token"), aad)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every value gets its own data key and nonce")
+
+	plain, err := k.Open(sealed, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t-token", string(plain))
+}
+
+func TestKeyring_OpenFailures(t *testing.T) {
+	k := testKeyring(t, 'k')
+	sealed, err := k.Seal([]byte("token"), []byte("a"))
+	require.NoError(t, err)
+
+	_, err = k.Open(sealed, []byte("b"))
+	assert.Error(t, err, "a value moved to another row does not open")
+
+	_, err = testKeyring(t, 'o').Open(sealed, []byte("a"))
+	assert.ErrorIs(t, err, ErrWrongKey)
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = k.Open(tampered, []byte("a"))
+	assert.Error(t, err)
+
+	_, err = k.Open(sealed[:10], []byte("a"))
+	assert.Error(t, err)
+}
+
+func TestNewKeyring_RejectsBadKeys(t *testing.T) {
+	_, err := NewKeyring("not base64!")
+	assert.Error(t, err)
+	_, err = NewKeyring(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.ErrorContains(t, err, "32 bytes")
+}
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		typ     domain.AnalysisLinkType
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{domain.AnalysisLinkJira, " proj-1234 ", "PROJ-1234", false},
+		{domain.AnalysisLinkJira, "PROJ1234", "", true},
+		{domain.AnalysisLinkServiceNow, "inc0012345", "INC0012345", false},
+		{domain.AnalysisLinkServiceNow, "INC-1", "", true},
+		{domain.AnalysisLinkURL, "https://wiki.example.com/p/1", "https://wiki.example.com/p/1", false},
+		{domain.AnalysisLinkURL, "javascript:alert(1)", "", true},
+		{"github", "x", "", true},
+	}
+	for _, tc := range tests {
+		got, err := NormalizeKey(tc.typ, tc.key)
+		if tc.wantErr {
+			assert.Error(t, err, tc.key)
+			continue
+		}
+		require.NoError(t, err, tc.key)
+		assert.Equal(t, tc.want, got)
+	}
+}
+
+func TestLinkURL(t *testing.T) {
+	assert.Equal(t, "https://acme.atlassian.net/browse/PROJ-1", LinkURL(domain.AnalysisLinkJira, "https://acme.atlassian.net", "PROJ-1"))
+	assert.Equal(t, "https://acme.service-now.com/nav_to.do?uri=task.do%3Fsysparm_query%3Dnumber%3DINC0012345",
+		LinkURL(domain.AnalysisLinkServiceNow, "https://acme.service-now.com", "INC0012345"))
+	assert.Equal(t, "https://x.test/a", LinkURL(domain.AnalysisLinkURL, "", "https://x.test/a"))
+	assert.Empty(t, LinkURL(domain.AnalysisLinkJira, "", "PROJ-1"))
+}
+
+// newTestClient returns the client of an integration of type t with
+// instance srv.
+func newTestClient(t *testing.T, typ domain.AnalysisLinkType, srv *httptest.Server, username string) Client {
+	t.Helper()
+	k := testKeyring(t, 'k')
+	tenantID := uuid.New()
+	sealed, err := k.Seal([]byte("api-token"), TokenAAD(tenantID, typ))
+	require.NoError(t, err)
+	c, err := NewClient(&domain.TicketingIntegration{
+		TenantID: tenantID, Type: typ, BaseURL: srv.URL, Username: username, Sealed: sealed,
+	}, k, srv.Client())
+	require.NoError(t, err)
+	return c
+}
+
+func TestJiraClient_Lookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer api-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "status,summary", r.URL.Query().Get("fields"))
+		switch r.URL.Path {
+		case "/rest/api/2/issue/PROJ-1234":
+			_, _ = w.Write([]byte(`{"key":"PROJ-1234","fields":{"summary":"Slow HPD submit","status":{"name":"In Progress"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	c := newTestClient(t, domain.AnalysisLinkJira, srv, "")
+
+	rec, err := c.Lookup(context.Background(), "PROJ-1234")
+	require.NoError(t, err)
+	assert.Equal(t, &Record{Key: "PROJ-1234", Status: "In Progress", Summary: "Slow HPD submit"}, rec)
+
+	_, err = c.Lookup(context.Background(), "PROJ-9")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestServiceNowClient_Lookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "svc", user)
+		assert.Equal(t, "api-token", pass)
+		assert.Equal(t, "/api/now/table/task", r.URL.Path)
+		if r.URL.Query().Get("sysparm_query") == "number=INC0012345" {
+			_, _ = w.Write([]byte(`{"result":[{"number":"INC0012345","state":"Resolved","short_description":"Email down"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":[]}`))
+	}))
+	defer srv.Close()
+	c := newTestClient(t, domain.AnalysisLinkServiceNow, srv, "svc")
+
+	rec, err := c.Lookup(context.Background(), "INC0012345")
+	require.NoError(t, err)
+	assert.Equal(t, &Record{Key: "INC0012345", Status: "Resolved", Summary: "Email down"}, rec)
+
+	_, err = c.Lookup(context.Background(), "INC0000001")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_Failures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/api/2/issue/AUTH-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	c := newTestClient(t, domain.AnalysisLinkJira, srv, "")
+
+	_, err := c.Lookup(context.Background(), "AUTH-1")
+	assert.ErrorContains(t, err, "credentials rejected")
+	_, err = c.Lookup(context.Background(), "PROJ-1")
+	assert.ErrorContains(t, err, "unexpected status 502")
+
+	srv.Close()
+	_, err = c.Lookup(context.Background(), "PROJ-1")
+	require.Error(t, err, "an unreachable instance fails the lookup")
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
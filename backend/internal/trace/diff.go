@@ -0,0 +1,278 @@
+package trace
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// DiffTraces aligns the entries of two traces of the same operation, a
+// baseline and a candidate, and returns the spans both ran with their
+// duration deltas, the spans only one of them ran, and the totals of both.
+//
+// Entries are compared by signature: the log type with the API code and
+// form, the SQL statement and table, the filter name or the escalation
+// name. The alignment is the longest common subsequence of the two
+// signature sequences, so repeated filters pair up in order. Spans left
+// over on both sides with the same signature are then paired as moved,
+// which tolerates minor reordering. The result does not depend on the
+// order of the given entries.
+func DiffTraces(baseline, candidate []LogEntry) domain.TraceDiff {
+	a, b := sortedEntries(baseline), sortedEntries(candidate)
+	sigA, sigB := signatures(a), signatures(b)
+
+	rows := alignSignatures(sigA, sigB)
+	pairMoved(rows, sigA, sigB)
+
+	startA, startB := traceStart(a), traceStart(b)
+	diff := domain.TraceDiff{
+		Spans:     make([]domain.TraceDiffSpan, 0, len(rows)),
+		Baseline:  traceStats(a),
+		Candidate: traceStats(b),
+	}
+	for _, row := range rows {
+		if row.skip {
+			continue
+		}
+		span := domain.TraceDiffSpan{Moved: row.moved}
+		var e LogEntry
+		if row.a >= 0 {
+			e = a[row.a]
+			span.Signature = sigA[row.a]
+			span.Baseline = diffSide(e, startA)
+		}
+		if row.b >= 0 {
+			e = b[row.b]
+			span.Signature = sigB[row.b]
+			span.Candidate = diffSide(e, startB)
+		}
+		span.LogType = e.LogType
+		span.Name = strings.TrimPrefix(span.Signature, string(e.LogType)+":")
+
+		switch {
+		case span.Baseline != nil && span.Candidate != nil:
+			span.Status = domain.TraceDiffMatched
+			span.DeltaMS = span.Candidate.DurationMS - span.Baseline.DurationMS
+			diff.Matched++
+		case span.Baseline != nil:
+			span.Status = domain.TraceDiffBaselineOnly
+			diff.BaselineOnly++
+		default:
+			span.Status = domain.TraceDiffCandidateOnly
+			diff.CandidateOnly++
+		}
+		diff.Spans = append(diff.Spans, span)
+	}
+	diff.Delta = statsDelta(diff.Baseline, diff.Candidate)
+	return diff
+}
+
+// diffRow is one row of an alignment: indexes into the baseline and the
+// candidate, -1 for the side that did not run the span. skip drops a
+// candidate-only row whose span was paired as moved with an earlier row.
+type diffRow struct {
+	a, b  int
+	moved bool
+	skip  bool
+}
+
+// sortedEntries returns the entries in the order of BuildHierarchy: by
+// timestamp, then thread and line number.
+func sortedEntries(entries []LogEntry) []LogEntry {
+	sorted := make([]LogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Timestamp.Equal(sorted[j].Timestamp.Time) {
+			if sorted[i].ThreadID == sorted[j].ThreadID {
+				return sorted[i].LineNumber < sorted[j].LineNumber
+			}
+			return sorted[i].ThreadID < sorted[j].ThreadID
+		}
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp.Time)
+	})
+	return sorted
+}
+
+func signatures(entries []LogEntry) []string {
+	sigs := make([]string, len(entries))
+	for i, e := range entries {
+		sigs[i] = spanSignature(e)
+	}
+	return sigs
+}
+
+// spanSignature identifies the work an entry did, independently of when
+// and how long it ran.
+func spanSignature(e LogEntry) string {
+	var name string
+	switch e.LogType {
+	case LogTypeAPI:
+		name = e.APICode
+		if e.Form != "" {
+			name += " " + e.Form
+		}
+	case LogTypeSQL:
+		name = e.SQLTable
+		if verb := sqlVerb(e.SQLStatement); verb != "" {
+			name = verb + " " + name
+		}
+	case LogTypeFilter:
+		name = e.FilterName
+	case LogTypeEscalation:
+		name = e.EscName
+	}
+	return string(e.LogType) + ":" + name
+}
+
+// sqlVerb returns the upper-cased first word of a SQL statement.
+func sqlVerb(stmt string) string {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// alignSignatures returns the rows of the longest common subsequence
+// alignment of a and b. The common prefix and suffix are matched directly,
+// so that the table only covers the part of the traces that differs.
+func alignSignatures(a, b []string) []diffRow {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	rows := make([]diffRow, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		rows = append(rows, diffRow{a: i, b: i})
+	}
+
+	// lcs[i*w+j] is the length of the longest common subsequence of the
+	// middles of a and b from i and j on.
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	w := len(mb) + 1
+	lcs := make([]int32, (len(ma)+1)*w)
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else {
+				lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			rows = append(rows, diffRow{a: prefix + i, b: prefix + j})
+			i++
+			j++
+		case j == len(mb) || (i < len(ma) && lcs[(i+1)*w+j] >= lcs[i*w+j+1]):
+			rows = append(rows, diffRow{a: prefix + i, b: -1})
+			i++
+		default:
+			rows = append(rows, diffRow{a: -1, b: prefix + j})
+			j++
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		rows = append(rows, diffRow{a: len(a) - suffix + k, b: len(b) - suffix + k})
+	}
+	return rows
+}
+
+// pairMoved pairs, in order, the baseline-only and candidate-only rows of
+// the same signature. The pair takes the baseline's row and the
+// candidate's row is skipped.
+func pairMoved(rows []diffRow, sigA, sigB []string) {
+	unmatched := make(map[string][]int)
+	for k, row := range rows {
+		if row.b >= 0 && row.a < 0 {
+			unmatched[sigB[row.b]] = append(unmatched[sigB[row.b]], k)
+		}
+	}
+	for k := range rows {
+		row := &rows[k]
+		if row.a < 0 || row.b >= 0 {
+			continue
+		}
+		candidates := unmatched[sigA[row.a]]
+		if len(candidates) == 0 {
+			continue
+		}
+		other := &rows[candidates[0]]
+		unmatched[sigA[row.a]] = candidates[1:]
+		row.b, row.moved = other.b, true
+		other.skip = true
+	}
+}
+
+func traceStart(entries []LogEntry) time.Time {
+	if len(entries) == 0 {
+		return time.Time{}
+	}
+	return entries[0].Timestamp.Time
+}
+
+func diffSide(e LogEntry, start time.Time) *domain.TraceDiffSide {
+	return &domain.TraceDiffSide{
+		EntryID:     e.EntryID,
+		LineNumber:  e.LineNumber,
+		FileNumber:  e.FileNumber,
+		OffsetMS:    e.Timestamp.Sub(start).Milliseconds(),
+		DurationMS:  int64(e.DurationMS),
+		QueueTimeMS: int64(e.QueueTimeMS),
+		Success:     e.Success,
+	}
+}
+
+// traceStats totals sorted trace entries. DurationMS runs from the first
+// entry's start to the latest end of an entry.
+func traceStats(entries []LogEntry) domain.TraceDiffStats {
+	stats := domain.TraceDiffStats{Entries: len(entries)}
+	if len(entries) == 0 {
+		return stats
+	}
+	start := entries[0].Timestamp.Time
+	for _, e := range entries {
+		end := e.Timestamp.Add(time.Duration(e.DurationMS) * time.Millisecond)
+		stats.DurationMS = max(stats.DurationMS, end.Sub(start).Milliseconds())
+		stats.MaxQueueWaitMS = max(stats.MaxQueueWaitMS, int64(e.QueueTimeMS))
+		if !e.Success {
+			stats.ErrorCount++
+		}
+		switch e.LogType {
+		case LogTypeAPI:
+			stats.APICount++
+		case LogTypeSQL:
+			stats.SQLCount++
+			stats.SQLTimeMS += int64(e.DurationMS)
+		case LogTypeFilter:
+			stats.FilterCount++
+			stats.FilterTimeMS += int64(e.DurationMS)
+		}
+	}
+	return stats
+}
+
+func statsDelta(a, b domain.TraceDiffStats) domain.TraceDiffStats {
+	return domain.TraceDiffStats{
+		Entries:        b.Entries - a.Entries,
+		DurationMS:     b.DurationMS - a.DurationMS,
+		APICount:       b.APICount - a.APICount,
+		SQLCount:       b.SQLCount - a.SQLCount,
+		SQLTimeMS:      b.SQLTimeMS - a.SQLTimeMS,
+		FilterCount:    b.FilterCount - a.FilterCount,
+		FilterTimeMS:   b.FilterTimeMS - a.FilterTimeMS,
+		MaxQueueWaitMS: b.MaxQueueWaitMS - a.MaxQueueWaitMS,
+		ErrorCount:     b.ErrorCount - a.ErrorCount,
+	}
+}
@@ -0,0 +1,194 @@
+package trace
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diffSpan describes one entry of a test trace.
+type diffSpan struct {
+	logType    domain.LogType
+	name       string
+	durationMS uint32
+}
+
+func api(name string, ms uint32) diffSpan  { return diffSpan{domain.LogTypeAPI, name, ms} }
+func sql(table string, ms uint32) diffSpan { return diffSpan{domain.LogTypeSQL, table, ms} }
+func fltr(name string, ms uint32) diffSpan { return diffSpan{domain.LogTypeFilter, name, ms} }
+
+// buildTrace lays the spans out one after another, 10ms apart.
+func buildTrace(id string, spans ...diffSpan) []domain.LogEntry {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	entries := make([]domain.LogEntry, len(spans))
+	for i, s := range spans {
+		e := domain.LogEntry{
+			EntryID:    fmt.Sprintf("%s-%d", id, i),
+			LineNumber: uint32(i + 1),
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i*10) * time.Millisecond)),
+			LogType:    s.logType,
+			DurationMS: s.durationMS,
+			ThreadID:   "t1",
+			TraceID:    id,
+			Success:    true,
+		}
+		switch s.logType {
+		case domain.LogTypeAPI:
+			e.APICode, e.Form = "SE", s.name
+		case domain.LogTypeSQL:
+			e.SQLTable, e.SQLStatement = s.name, "SELECT * FROM "+s.name
+		case domain.LogTypeFilter:
+			e.FilterName = s.name
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+func diffStatuses(d domain.TraceDiff) []string {
+	out := make([]string, len(d.Spans))
+	for i, s := range d.Spans {
+		out[i] = s.Status + " " + s.Signature
+	}
+	return out
+}
+
+func TestDiffTraces_Identical(t *testing.T) {
+	a := buildTrace("a", api("SRM:Request", 300), fltr("SRM:REQ:Init", 20), sql("T100", 5))
+	b := buildTrace("b", api("SRM:Request", 9000), fltr("SRM:REQ:Init", 20), sql("T100", 8000))
+
+	d := DiffTraces(a, b)
+
+	assert.Equal(t, 3, d.Matched)
+	assert.Zero(t, d.BaselineOnly)
+	assert.Zero(t, d.CandidateOnly)
+	require.Len(t, d.Spans, 3)
+	assert.Equal(t, "API:SE SRM:Request", d.Spans[0].Signature)
+	assert.Equal(t, "SE SRM:Request", d.Spans[0].Name)
+	assert.Equal(t, int64(8700), d.Spans[0].DeltaMS)
+	assert.Equal(t, int64(0), d.Spans[1].DeltaMS)
+	assert.Equal(t, "SQL:SELECT T100", d.Spans[2].Signature)
+	assert.Equal(t, int64(7995), d.Spans[2].DeltaMS)
+	assert.Equal(t, "b-2", d.Spans[2].Candidate.EntryID)
+	assert.Equal(t, int64(20), d.Spans[2].Candidate.OffsetMS)
+
+	assert.Equal(t, int64(300), d.Baseline.DurationMS)
+	assert.Equal(t, int64(9000), d.Candidate.DurationMS)
+	assert.Equal(t, int64(8700), d.Delta.DurationMS)
+	assert.Equal(t, int64(7995), d.Delta.SQLTimeMS)
+	assert.Zero(t, d.Delta.SQLCount)
+}
+
+func TestDiffTraces_Disjoint(t *testing.T) {
+	a := buildTrace("a", api("HPD:Help Desk", 100), sql("T1", 5))
+	b := buildTrace("b", fltr("CHG:Init", 10), sql("T2", 5), sql("T3", 5))
+
+	d := DiffTraces(a, b)
+
+	assert.Zero(t, d.Matched)
+	assert.Equal(t, 2, d.BaselineOnly)
+	assert.Equal(t, 3, d.CandidateOnly)
+	for _, s := range d.Spans {
+		assert.Zero(t, s.DeltaMS)
+		assert.True(t, (s.Baseline == nil) != (s.Candidate == nil), s.Signature)
+	}
+	assert.Equal(t, 1, d.Delta.SQLCount)
+	assert.Equal(t, 1, d.Delta.FilterCount)
+	assert.Equal(t, -1, d.Delta.APICount)
+}
+
+func TestDiffTraces_RepeatedSpans(t *testing.T) {
+	a := buildTrace("a",
+		api("SRM:Request", 300),
+		fltr("SRM:REQ:Set", 10), sql("T100", 5),
+		fltr("SRM:REQ:Set", 10), sql("T100", 5),
+	)
+	b := buildTrace("b",
+		api("SRM:Request", 900),
+		fltr("SRM:REQ:Set", 10), sql("T100", 5),
+		fltr("SRM:REQ:Set", 10), sql("T100", 5),
+		fltr("SRM:REQ:Set", 10), sql("T100", 400),
+	)
+
+	d := DiffTraces(a, b)
+
+	assert.Equal(t, 5, d.Matched)
+	assert.Zero(t, d.BaselineOnly)
+	assert.Equal(t, 2, d.CandidateOnly)
+	assert.Equal(t, []string{
+		"matched API:SE SRM:Request",
+		"matched FLTR:SRM:REQ:Set",
+		"matched SQL:SELECT T100",
+		"matched FLTR:SRM:REQ:Set",
+		"matched SQL:SELECT T100",
+		"candidate_only FLTR:SRM:REQ:Set",
+		"candidate_only SQL:SELECT T100",
+	}, diffStatuses(d))
+	assert.Equal(t, 1, d.Delta.FilterCount)
+	assert.Equal(t, int64(400), d.Delta.SQLTimeMS)
+}
+
+func TestDiffTraces_Truncated(t *testing.T) {
+	full := []diffSpan{api("SRM:Request", 300), fltr("F1", 10), sql("T1", 5), fltr("F2", 10), sql("T2", 5)}
+	a := buildTrace("a", full...)
+	b := buildTrace("b", full[:2]...)
+
+	d := DiffTraces(a, b)
+
+	assert.Equal(t, []string{
+		"matched API:SE SRM:Request",
+		"matched FLTR:F1",
+		"baseline_only SQL:SELECT T1",
+		"baseline_only FLTR:F2",
+		"baseline_only SQL:SELECT T2",
+	}, diffStatuses(d))
+	assert.Equal(t, -3, d.Delta.Entries)
+
+	empty := DiffTraces(a, nil)
+	assert.Equal(t, 5, empty.BaselineOnly)
+	assert.Equal(t, domain.TraceDiffStats{}, empty.Candidate)
+	assert.NotNil(t, DiffTraces(nil, nil).Spans)
+}
+
+func TestDiffTraces_Reordered(t *testing.T) {
+	a := buildTrace("a", api("SRM:Request", 300), fltr("F1", 10), fltr("F2", 10), sql("T1", 5))
+	b := buildTrace("b", api("SRM:Request", 300), fltr("F2", 30), fltr("F1", 10), sql("T1", 5))
+
+	d := DiffTraces(a, b)
+
+	assert.Equal(t, 4, d.Matched)
+	assert.Zero(t, d.BaselineOnly)
+	assert.Zero(t, d.CandidateOnly)
+	require.Len(t, d.Spans, 4)
+	var moved []string
+	for _, s := range d.Spans {
+		if s.Moved {
+			moved = append(moved, s.Signature)
+		}
+		if s.Signature == "FLTR:F2" {
+			assert.Equal(t, int64(20), s.DeltaMS)
+			assert.Equal(t, "b-1", s.Candidate.EntryID)
+		}
+	}
+	assert.Len(t, moved, 1, "one of the swapped filters is aligned, the other moved")
+}
+
+func TestDiffTraces_Stats(t *testing.T) {
+	a := buildTrace("a", api("SRM:Request", 300), sql("T1", 40), sql("T2", 60))
+	a[1].QueueTimeMS = 250
+	a[2].Success = false
+	b := buildTrace("b", sql("T2", 60), api("SRM:Request", 300), sql("T1", 40))
+
+	d := DiffTraces(a, b)
+
+	assert.Equal(t, domain.TraceDiffStats{
+		Entries: 3, DurationMS: 300, APICount: 1, SQLCount: 2, SQLTimeMS: 100, MaxQueueWaitMS: 250, ErrorCount: 1,
+	}, d.Baseline)
+	assert.Equal(t, int64(-250), d.Delta.MaxQueueWaitMS)
+	assert.Equal(t, -1, d.Delta.ErrorCount)
+	assert.Equal(t, int64(10), d.Delta.DurationMS, "the API call starts 10ms in")
+}
@@ -36,16 +36,16 @@ func (b *HierarchyBuilder) Build() []SpanNode {
 	sorted := make([]LogEntry, len(b.entries))
 	copy(sorted, b.entries)
 	sort.Slice(sorted, func(i, j int) bool {
-		if sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+		if sorted[i].Timestamp.Equal(sorted[j].Timestamp.Time) {
 			if sorted[i].ThreadID == sorted[j].ThreadID {
 				return sorted[i].LineNumber < sorted[j].LineNumber
 			}
 			return sorted[i].ThreadID < sorted[j].ThreadID
 		}
-		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp.Time)
 	})
 
-	traceStart := sorted[0].Timestamp
+	traceStart := sorted[0].Timestamp.Time
 	nodes := make([]SpanNode, 0, len(sorted))
 	for _, e := range sorted {
 		node := entryToSpanNode(e, traceStart)
@@ -189,6 +189,12 @@ func entryToFields(e LogEntry) map[string]interface{} {
 	if e.Form != "" {
 		fields["form"] = e.Form
 	}
+	if e.Client != "" {
+		fields["client"] = e.Client
+	}
+	if e.ClientIP != "" {
+		fields["client_ip"] = e.ClientIP
+	}
 	if e.SQLTable != "" {
 		fields["sql_table"] = e.SQLTable
 	}
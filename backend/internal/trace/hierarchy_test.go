@@ -14,7 +14,7 @@ func TestBuildHierarchy_SingleAPIRoot(t *testing.T) {
 	entries := []domain.LogEntry{
 		{
 			EntryID:    "api-1",
-			Timestamp:  base,
+			Timestamp:  domain.NewTimestamp(base),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 100,
 			ThreadID:   "t1",
@@ -25,7 +25,7 @@ func TestBuildHierarchy_SingleAPIRoot(t *testing.T) {
 		},
 		{
 			EntryID:     "filter-1",
-			Timestamp:   base.Add(5 * time.Millisecond),
+			Timestamp:   domain.NewTimestamp(base.Add(5 * time.Millisecond)),
 			LogType:     domain.LogTypeFilter,
 			DurationMS:  20,
 			ThreadID:    "t1",
@@ -36,7 +36,7 @@ func TestBuildHierarchy_SingleAPIRoot(t *testing.T) {
 		},
 		{
 			EntryID:    "sql-1",
-			Timestamp:  base.Add(10 * time.Millisecond),
+			Timestamp:  domain.NewTimestamp(base.Add(10 * time.Millisecond)),
 			LogType:    domain.LogTypeSQL,
 			DurationMS: 5,
 			ThreadID:   "t1",
@@ -65,7 +65,7 @@ func TestBuildHierarchy_3LevelFilterDepth(t *testing.T) {
 	entries := []domain.LogEntry{
 		{
 			EntryID:    "api-1",
-			Timestamp:  base,
+			Timestamp:  domain.NewTimestamp(base),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 500,
 			ThreadID:   "t1",
@@ -74,7 +74,7 @@ func TestBuildHierarchy_3LevelFilterDepth(t *testing.T) {
 		},
 		{
 			EntryID:     "filter-1",
-			Timestamp:   base.Add(10 * time.Millisecond),
+			Timestamp:   domain.NewTimestamp(base.Add(10 * time.Millisecond)),
 			LogType:     domain.LogTypeFilter,
 			DurationMS:  200,
 			ThreadID:    "t1",
@@ -84,7 +84,7 @@ func TestBuildHierarchy_3LevelFilterDepth(t *testing.T) {
 		},
 		{
 			EntryID:     "filter-2",
-			Timestamp:   base.Add(20 * time.Millisecond),
+			Timestamp:   domain.NewTimestamp(base.Add(20 * time.Millisecond)),
 			LogType:     domain.LogTypeFilter,
 			DurationMS:  100,
 			ThreadID:    "t1",
@@ -94,7 +94,7 @@ func TestBuildHierarchy_3LevelFilterDepth(t *testing.T) {
 		},
 		{
 			EntryID:     "filter-3",
-			Timestamp:   base.Add(30 * time.Millisecond),
+			Timestamp:   domain.NewTimestamp(base.Add(30 * time.Millisecond)),
 			LogType:     domain.LogTypeFilter,
 			DurationMS:  50,
 			ThreadID:    "t1",
@@ -116,7 +116,7 @@ func TestBuildHierarchy_FlatTrace(t *testing.T) {
 	entries := []domain.LogEntry{
 		{
 			EntryID:    "api-1",
-			Timestamp:  base,
+			Timestamp:  domain.NewTimestamp(base),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 10,
 			ThreadID:   "t1",
@@ -125,7 +125,7 @@ func TestBuildHierarchy_FlatTrace(t *testing.T) {
 		},
 		{
 			EntryID:    "api-2",
-			Timestamp:  base.Add(20 * time.Millisecond),
+			Timestamp:  domain.NewTimestamp(base.Add(20 * time.Millisecond)),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 10,
 			ThreadID:   "t1",
@@ -134,7 +134,7 @@ func TestBuildHierarchy_FlatTrace(t *testing.T) {
 		},
 		{
 			EntryID:    "api-3",
-			Timestamp:  base.Add(40 * time.Millisecond),
+			Timestamp:  domain.NewTimestamp(base.Add(40 * time.Millisecond)),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 10,
 			ThreadID:   "t1",
@@ -153,7 +153,7 @@ func TestBuildHierarchy_ConcurrentSiblings(t *testing.T) {
 	entries := []domain.LogEntry{
 		{
 			EntryID:    "api-1",
-			Timestamp:  base,
+			Timestamp:  domain.NewTimestamp(base),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 100,
 			ThreadID:   "t1",
@@ -162,7 +162,7 @@ func TestBuildHierarchy_ConcurrentSiblings(t *testing.T) {
 		},
 		{
 			EntryID:    "api-2",
-			Timestamp:  base.Add(5 * time.Millisecond),
+			Timestamp:  domain.NewTimestamp(base.Add(5 * time.Millisecond)),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 50,
 			ThreadID:   "t2",
@@ -187,7 +187,7 @@ func TestBuildHierarchy_SingleLogType(t *testing.T) {
 	entries := []domain.LogEntry{
 		{
 			EntryID:    "sql-1",
-			Timestamp:  base,
+			Timestamp:  domain.NewTimestamp(base),
 			LogType:    domain.LogTypeSQL,
 			DurationMS: 10,
 			ThreadID:   "t1",
@@ -197,7 +197,7 @@ func TestBuildHierarchy_SingleLogType(t *testing.T) {
 		},
 		{
 			EntryID:    "sql-2",
-			Timestamp:  base.Add(20 * time.Millisecond),
+			Timestamp:  domain.NewTimestamp(base.Add(20 * time.Millisecond)),
 			LogType:    domain.LogTypeSQL,
 			DurationMS: 10,
 			ThreadID:   "t1",
@@ -216,7 +216,7 @@ func TestBuildHierarchy_RPCIDFallback(t *testing.T) {
 	entries := []domain.LogEntry{
 		{
 			EntryID:    "api-1",
-			Timestamp:  base,
+			Timestamp:  domain.NewTimestamp(base),
 			LogType:    domain.LogTypeAPI,
 			DurationMS: 100,
 			ThreadID:   "t1",
@@ -226,7 +226,7 @@ func TestBuildHierarchy_RPCIDFallback(t *testing.T) {
 		},
 		{
 			EntryID:    "sql-1",
-			Timestamp:  base.Add(10 * time.Millisecond),
+			Timestamp:  domain.NewTimestamp(base.Add(10 * time.Millisecond)),
 			LogType:    domain.LogTypeSQL,
 			DurationMS: 20,
 			ThreadID:   "t1",
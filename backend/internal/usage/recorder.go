@@ -0,0 +1,79 @@
+// Package usage accounts per-tenant usage for billing and capacity planning.
+// Usage events are queued in memory and written in the background, so
+// accounting never adds latency or failure modes to the operations it
+// measures. Events lost before they are written are backfilled by the
+// worker's nightly reconciliation.
+package usage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/batch"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// DefaultBufferSize is the number of events queued before new ones are
+	// dropped.
+	DefaultBufferSize = batch.DefaultBufferSize
+
+	// DefaultFlushInterval is how often queued events are written.
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// Store persists usage events idempotently.
+type Store interface {
+	RecordUsageEvents(ctx context.Context, events []domain.UsageEvent) (int, error)
+}
+
+// Recorder buffers usage events and writes them to the store from Run.
+type Recorder struct {
+	store  Store
+	writer *batch.Writer[domain.UsageEvent]
+}
+
+// NewRecorder creates a Recorder queueing up to bufferSize events and
+// writing them every flushInterval or whenever a full batch is queued.
+// Non-positive values select the defaults.
+func NewRecorder(store Store, bufferSize int, flushInterval time.Duration) *Recorder {
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	r := &Recorder{store: store}
+	r.writer = batch.NewWriter(r.write, bufferSize, flushInterval)
+	return r
+}
+
+// Record queues an event without blocking. Events are dropped when the
+// buffer is full. A nil Recorder ignores every event.
+func (r *Recorder) Record(e domain.UsageEvent) {
+	if r == nil {
+		return
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+	if !r.writer.Offer(e) {
+		slog.Warn("usage buffer full, event dropped",
+			"tenant_id", e.TenantID.String(),
+			"metric", e.Metric,
+			"source_id", e.SourceID.String(),
+		)
+	}
+}
+
+// Run writes queued events until ctx is cancelled, then writes the events
+// still queued and returns.
+func (r *Recorder) Run(ctx context.Context) {
+	r.writer.Run(ctx)
+}
+
+// write stores a batch of events. Failed writes are logged and dropped;
+// reconciliation backfills them.
+func (r *Recorder) write(ctx context.Context, events []domain.UsageEvent) {
+	if _, err := r.store.RecordUsageEvents(ctx, events); err != nil {
+		slog.Warn("failed to record usage events", "count", len(events), "error", err)
+	}
+}
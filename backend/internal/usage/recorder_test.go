@@ -0,0 +1,137 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// memoryStore is an idempotent in-memory Store keyed like usage_events.
+type memoryStore struct {
+	mu     sync.Mutex
+	events map[string]domain.UsageEvent
+	calls  int
+	err    error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{events: make(map[string]domain.UsageEvent)}
+}
+
+func (s *memoryStore) RecordUsageEvents(_ context.Context, events []domain.UsageEvent) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+	n := 0
+	for _, e := range events {
+		key := e.TenantID.String() + "/" + string(e.Metric) + "/" + e.SourceID.String()
+		if _, ok := s.events[key]; !ok {
+			s.events[key] = e
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) total(metric domain.UsageMetric) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sum int64
+	for _, e := range s.events {
+		if e.Metric == metric {
+			sum += e.Amount
+		}
+	}
+	return sum
+}
+
+func TestRecorder_WritesOnStop(t *testing.T) {
+	store := newMemoryStore()
+	r := NewRecorder(store, 16, time.Hour)
+	tenant, file := uuid.New(), uuid.New()
+
+	r.Record(domain.UsageEvent{TenantID: tenant, Metric: domain.UsageBytesUploaded, SourceID: file, Amount: 2048})
+	// A retried upload records the same file again.
+	r.Record(domain.UsageEvent{TenantID: tenant, Metric: domain.UsageBytesUploaded, SourceID: file, Amount: 2048})
+	r.Record(domain.UsageEvent{TenantID: tenant, Metric: domain.UsageAnalysesRun, SourceID: uuid.New(), Amount: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	assert.Equal(t, int64(2048), store.total(domain.UsageBytesUploaded))
+	assert.Equal(t, int64(1), store.total(domain.UsageAnalysesRun))
+}
+
+func TestRecorder_FlushesOnInterval(t *testing.T) {
+	store := newMemoryStore()
+	r := NewRecorder(store, 16, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	r.Record(domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageAIQueries, SourceID: uuid.New(), Amount: 1})
+	require.Eventually(t, func() bool { return store.total(domain.UsageAIQueries) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRecorder_DropsWhenFull(t *testing.T) {
+	store := newMemoryStore()
+	r := NewRecorder(store, 1, time.Hour)
+
+	// Nothing drains the buffer: the second event must not block.
+	r.Record(domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageAnalysesRun, SourceID: uuid.New(), Amount: 1})
+	r.Record(domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageAnalysesRun, SourceID: uuid.New(), Amount: 1})
+	assert.Equal(t, 1, r.writer.Len())
+}
+
+func TestRecorder_StoreFailureDoesNotStop(t *testing.T) {
+	store := newMemoryStore()
+	store.err = errors.New("postgres down")
+	r := NewRecorder(store, 16, time.Hour)
+	r.Record(domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageWorkerMS, SourceID: uuid.New(), Amount: 500})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Run(ctx)
+
+	assert.Equal(t, 1, store.calls)
+	assert.Zero(t, r.writer.Len())
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+	assert.NotPanics(t, func() {
+		r.Record(domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageAIQueries, SourceID: uuid.New(), Amount: 1})
+	})
+}
+
+func TestRecorder_StampsOccurredAt(t *testing.T) {
+	store := newMemoryStore()
+	r := NewRecorder(store, 1, time.Hour)
+	r.Record(domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageAIQueries, SourceID: uuid.New(), Amount: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Run(ctx)
+
+	require.Len(t, store.events, 1)
+	for _, e := range store.events {
+		assert.WithinDuration(t, time.Now(), e.OccurredAt, time.Minute)
+	}
+}
@@ -31,6 +31,7 @@ type Anomaly struct {
 	Title       string      `json:"title"`
 	Description string      `json:"description"`
 	Metric      string      `json:"metric"`
+	Key         string      `json:"key"`
 	Value       float64     `json:"value"`
 	Baseline    float64     `json:"baseline"`
 	StdDev      float64     `json:"std_dev"`
@@ -93,6 +94,7 @@ func (d *AnomalyDetector) Detect(ctx context.Context, jobID, tenantID string, an
 				Title:       fmt.Sprintf("Anomalous %s: %s", metric, p.Key),
 				Description: fmt.Sprintf("%s for %s is %.1f (baseline: %.1f, %.1f\u03c3 deviation)", metric, p.Key, p.Value, mean, sigma),
 				Metric:      metric,
+				Key:         p.Key,
 				Value:       p.Value,
 				Baseline:    mean,
 				StdDev:      stddev,
@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// ErrJobCancelled is returned by ProcessJob when the job was cancelled by a
+// user while it ran. What the job stored has then been deleted and the job
+// marked cancelled.
+var ErrJobCancelled = errors.New("analysis cancelled")
+
+const (
+	// cancelCleanupTimeout bounds the undoing of a cancelled job, once its
+	// own context is done.
+	cancelCleanupTimeout = 30 * time.Second
+
+	// cancelPostgresEvery is how many polls of the Redis flag pass between
+	// two reads of the job in Postgres, which holds the request should the
+	// flag have been lost or never written.
+	cancelPostgresEvery = 5
+
+	// CancelFlagTTL is how long the Redis flag of a cancellation request
+	// outlives the request; the worker deletes it once the job is
+	// cancelled.
+	CancelFlagTTL = 24 * time.Hour
+)
+
+// JobCancelKey is the Redis key flagging that the cancellation of a job
+// was requested. The worker running the job polls it.
+func JobCancelKey(redis storage.RedisCache, tenantID, jobID string) string {
+	return redis.TenantKey(tenantID, "cancel", jobID)
+}
+
+// SetCancellationPoll makes each job poll for its cancellation every d
+// while it runs. Zero disables polling: a cancellation requested while a
+// job runs is then only seen when the job moves to storing or completes.
+func (p *Pipeline) SetCancellationPoll(d time.Duration) {
+	p.cancelPoll = d
+}
+
+// ProcessJob runs the full ingestion pipeline for an analysis job.
+//
+// A job whose cancellation is requested while it runs is stopped: its
+// context is cancelled, which kills the JAR and aborts downloads and
+// inserts in flight, and each stage checks for it before it starts and
+// between batches. What the job stored is then deleted, its temp files are
+// removed as it returns, and ProcessJob marks it cancelled and returns
+// ErrJobCancelled. A job cancelled while queued is skipped.
+func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error {
+	ctx, stop := p.watchCancellation(ctx, job)
+	err := p.processJob(ctx, job)
+	stop()
+	if err != nil && (errors.Is(err, ErrJobCancelled) || jobCancelled(ctx) != nil) {
+		p.cancelJob(ctx, job)
+		return ErrJobCancelled
+	}
+	return err
+}
+
+// jobCancelled returns ErrJobCancelled when ctx is that of a job whose
+// cancellation was seen, and nil otherwise. The stages check it at their
+// boundaries.
+func jobCancelled(ctx context.Context) error {
+	if errors.Is(context.Cause(ctx), ErrJobCancelled) {
+		return ErrJobCancelled
+	}
+	return nil
+}
+
+// watchCancellation returns a context of job that is cancelled, with cause
+// ErrJobCancelled, once the cancellation of the job is requested. stop
+// ends the watch.
+func (p *Pipeline) watchCancellation(ctx context.Context, job domain.AnalysisJob) (_ context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if p.cancelPoll <= 0 {
+		return ctx, func() { cancel(nil) }
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		pollCtx := storage.WithPrimary(storage.WithTenant(ctx, job.TenantID.String()))
+		ticker := p.clockOrReal().NewTicker(p.cancelPoll)
+		defer ticker.Stop()
+		for polls := 1; ; polls++ {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+			if p.cancelRequested(pollCtx, job, polls%cancelPostgresEvery == 0) {
+				cancel(ErrJobCancelled)
+				return
+			}
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		<-stopped
+		cancel(nil)
+	}
+}
+
+// cancelRequested reports whether the cancellation of job was requested,
+// from its Redis flag or, when readPostgres is set or there is no Redis,
+// from the job itself.
+func (p *Pipeline) cancelRequested(ctx context.Context, job domain.AnalysisJob, readPostgres bool) bool {
+	if p.redis != nil {
+		if v, err := p.redis.Get(ctx, JobCancelKey(p.redis, job.TenantID.String(), job.ID.String())); err == nil && v != "" {
+			return true
+		}
+	}
+	if !readPostgres && p.redis != nil {
+		return false
+	}
+	current, err := p.pg.GetJob(ctx, job.TenantID, job.ID)
+	return err == nil && (current.CancelRequestedAt != nil || current.Status == domain.JobStatusCancelled)
+}
+
+// skipCancelled handles a job whose move to parsing was refused because it
+// was cancelled. One cancelled while queued has nothing to undo and is
+// skipped; one whose cancellation was requested while an earlier run of it
+// was interrupted is cleaned up like a job cancelled while running.
+func (p *Pipeline) skipCancelled(ctx context.Context, job domain.AnalysisJob, logger *slog.Logger) error {
+	current, err := p.pg.GetJob(ctx, job.TenantID, job.ID)
+	if err == nil && current.Status == domain.JobStatusCancelled {
+		logger.Info("cancelled job skipped")
+		return nil
+	}
+	return ErrJobCancelled
+}
+
+// cancelJob deletes what the cancelled job stored, its log entries, cached
+// sections, checkpoint and kept JAR report, then marks it cancelled and
+// publishes its terminal progress and completion. The uploads the job had
+// in flight were aborted with its context; none is left half written.
+func (p *Pipeline) cancelJob(ctx context.Context, job domain.AnalysisJob) {
+	tenantID := job.TenantID.String()
+	jobID := job.ID.String()
+	ctx, cancel := context.WithTimeout(storage.WithPrimary(storage.WithTenant(context.WithoutCancel(ctx), tenantID)), cancelCleanupTimeout)
+	defer cancel()
+	logger := slog.With("job_id", jobID, "tenant_id", tenantID)
+
+	if err := p.ch.DeleteJobEntries(ctx, tenantID, jobID); err != nil {
+		logger.Warn("failed to delete entries of cancelled job", "error", err)
+	}
+	if p.redis != nil {
+		prefix := p.redis.TenantKey(tenantID, "dashboard", jobID)
+		for _, suffix := range dashboardCacheSuffixes {
+			if err := p.redis.Delete(ctx, prefix+suffix); err != nil {
+				logger.Warn("failed to delete cached section", "key", prefix+suffix, "error", err)
+			}
+		}
+	}
+	if p.checkpoints {
+		p.clearCheckpoint(ctx, job, logger)
+	}
+	if p.storeJAROutput {
+		key := JAROutputKey(tenantID, jobID)
+		if err := p.s3.Delete(ctx, key); err != nil {
+			logger.Warn("failed to delete JAR output of cancelled job", "s3_key", key, "error", err)
+		}
+	}
+
+	errMsg := ErrJobCancelled.Error()
+	err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusCancelled, &errMsg)
+	if p.redis != nil {
+		if err := p.redis.Delete(ctx, JobCancelKey(p.redis, tenantID, jobID)); err != nil {
+			logger.Warn("failed to delete cancellation flag", "error", err)
+		}
+	}
+	if errors.Is(err, storage.ErrJobCancelled) {
+		// Another run of the job cancelled it already and published as much.
+		return
+	}
+	if err != nil {
+		logger.Error("failed to mark job cancelled", "error", err)
+		return
+	}
+
+	now := p.now()
+	cancelled := job
+	cancelled.Status = domain.JobStatusCancelled
+	cancelled.ErrorMessage = &errMsg
+	cancelled.CompletedAt = &now
+	p.publishProgress(ctx, job, 0, domain.JobStatusCancelled, errMsg)
+	p.publishComplete(ctx, cancelled)
+	p.recordEvent(job, domain.JobEventCancelled, "", errMsg, nil)
+	logger.Info("job cancelled")
+}
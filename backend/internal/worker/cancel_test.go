@@ -0,0 +1,325 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// cancelCapture is the capture of the jobs cancelled.
+const cancelCapture = "<API > <TrID: tr-1> <TID: 0000000100> <RPC ID: 0000005000> <Queue: Fast        > <Client-RPC: 100200   > <USER: Demo> <Overlay-Group: 1         > /* Mon Mar 09 2026 08:00:00.0000 */ +GE HPD:Help Desk\n" +
+	"<API > <TrID: tr-2> <TID: 0000000100> <RPC ID: 0000005001> <Queue: Fast        > <Client-RPC: 100200   > <USER: Demo> <Overlay-Group: 1         > /* Mon Mar 09 2026 08:00:01.0000 */ +GE HPD:Help Desk\n"
+
+// flagRedis is a Redis holding the keys the pipeline writes, on which the
+// tests raise cancellation flags.
+type flagRedis struct {
+	mu      sync.Mutex
+	keys    map[string]bool
+	deleted []string
+}
+
+func newFlagRedis() *flagRedis { return &flagRedis{keys: map[string]bool{}} }
+
+func (r *flagRedis) Ping(context.Context) error { return nil }
+
+func (r *flagRedis) Get(_ context.Context, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys[key] {
+		return "1", nil
+	}
+	return "", errors.New("redis: nil")
+}
+
+func (r *flagRedis) Set(_ context.Context, key string, _ interface{}, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key] = true
+	return nil
+}
+
+func (r *flagRedis) Delete(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, key)
+	r.deleted = append(r.deleted, key)
+	return nil
+}
+
+func (r *flagRedis) TenantKey(tenantID, category, id string) string {
+	return tenantID + ":" + category + ":" + id
+}
+
+func (r *flagRedis) CheckRateLimit(context.Context, string, int, time.Duration) (bool, error) {
+	return true, nil
+}
+
+// requestCancel raises the cancellation flag of job, as the API does.
+func (r *flagRedis) requestCancel(job domain.AnalysisJob) {
+	_ = r.Set(context.Background(), JobCancelKey(r, job.TenantID.String(), job.ID.String()), "1", CancelFlagTTL)
+}
+
+func (r *flagRedis) wasDeleted(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.deleted {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelWorld is a pipeline of job with the expectations of a full run set.
+// The status updates in refused are refused as for a cancelled job.
+type cancelWorld struct {
+	job   domain.AnalysisJob
+	pg    *testutil.MockPostgresStore
+	ch    *testutil.MockClickHouseStore
+	s3    *testutil.MockObjectStorage
+	jar   *MockJARRunner
+	redis *flagRedis
+	pipe  *Pipeline
+
+	mu        sync.Mutex
+	statuses  []domain.JobStatus
+	completed []domain.AnalysisJob
+}
+
+var jobCancelledErr = fmt.Errorf("postgres: update job status: %w", storage.ErrJobCancelled)
+
+func newCancelWorld(refused ...domain.JobStatus) *cancelWorld {
+	job := newTestJob()
+	w := &cancelWorld{
+		job:   job,
+		pg:    &testutil.MockPostgresStore{},
+		ch:    &testutil.MockClickHouseStore{},
+		s3:    &testutil.MockObjectStorage{},
+		jar:   &MockJARRunner{},
+		redis: newFlagRedis(),
+	}
+	nats := &testutil.MockNATSStreamer{}
+
+	for _, status := range []domain.JobStatus{domain.JobStatusParsing, domain.JobStatusStoring, domain.JobStatusComplete, domain.JobStatusFailed, domain.JobStatusCancelled} {
+		var err error
+		for _, r := range refused {
+			if r == status {
+				err = jobCancelledErr
+			}
+		}
+		w.pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, status, mock.Anything).
+			Run(func(mock.Arguments) {
+				w.mu.Lock()
+				defer w.mu.Unlock()
+				w.statuses = append(w.statuses, status)
+			}).
+			Return(err).Maybe()
+	}
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil).Maybe()
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).
+		Run(func(args mock.Arguments) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.completed = append(w.completed, args.Get(3).(domain.AnalysisJob))
+		}).
+		Return(nil).Maybe()
+
+	file := &domain.LogFile{ID: job.FileID, TenantID: job.TenantID, S3Key: "logs/test.log", SizeBytes: int64(len(cancelCapture))}
+	w.pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil).Maybe()
+	w.pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil).Maybe()
+	w.pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil).Maybe()
+	w.pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil).Maybe()
+	w.pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil).Maybe()
+	w.pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil).Maybe()
+	w.pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
+	w.pg.On("UpdateJobRestarts", mock.Anything, job.TenantID, job.ID, mock.Anything).Return(nil).Maybe()
+	w.pg.On("ListIngestionFilterRules", mock.Anything, job.TenantID).Return([]domain.IngestionFilterRule{}, nil).Maybe()
+	w.pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(nil, "", nil).Maybe()
+	w.pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.Anything, mock.Anything).Return(nil).Maybe()
+	expectReconciliation(w.pg, w.ch, job, validJARCounts)
+	w.ch.On("BuildJobRollup", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil).Maybe()
+	w.ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil, errors.New("not needed")).Maybe()
+	w.ch.On("GetFocusMetrics", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything, mock.Anything).Return(nil, errors.New("not needed")).Maybe()
+	w.ch.On("GetJobVocabulary", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything).Return(nil, errors.New("not needed")).Maybe()
+	w.ch.On("DeleteJobEntries", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil).Maybe()
+
+	w.pipe = NewPipeline(w.pg, w.ch, w.s3, w.redis, nats, w.jar, nil)
+	return w
+}
+
+// download serves the capture.
+func (w *cancelWorld) download() {
+	w.s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader(cancelCapture)), nil)
+}
+
+// jarRuns has the JAR print the standard report.
+func (w *cancelWorld) jarRuns() {
+	w.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), w.job.JARFlags, w.job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: validJAROutput}, nil)
+}
+
+// insertsSucceed accepts the batches of entries.
+func (w *cancelWorld) insertsSucceed() {
+	w.ch.On("BatchInsertEntries", mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+// blockUntilCancelled makes a call raise the cancellation flag, then wait
+// for the watcher to cancel the job's context.
+func (w *cancelWorld) blockUntilCancelled(args mock.Arguments) {
+	w.redis.requestCancel(w.job)
+	<-args.Get(0).(context.Context).Done()
+}
+
+func (w *cancelWorld) assertCancelled(t *testing.T, err error) {
+	t.Helper()
+	require.ErrorIs(t, err, ErrJobCancelled)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	assert.Contains(t, w.statuses, domain.JobStatusCancelled)
+	require.Len(t, w.completed, 1, "one terminal job_complete, not a failure")
+	assert.Equal(t, domain.JobStatusCancelled, w.completed[0].Status)
+	w.ch.AssertCalled(t, "DeleteJobEntries", mock.Anything, w.job.TenantID.String(), w.job.ID.String())
+	assert.True(t, w.redis.wasDeleted(JobCancelKey(w.redis, w.job.TenantID.String(), w.job.ID.String())), "the flag is cleared")
+}
+
+func TestProcessJob_SkipsJobCancelledWhileQueued(t *testing.T) {
+	w := newCancelWorld(domain.JobStatusParsing)
+	cancelled := w.job
+	cancelled.Status = domain.JobStatusCancelled
+	w.pg.On("GetJob", mock.Anything, w.job.TenantID, w.job.ID).Return(&cancelled, nil)
+
+	require.NoError(t, w.pipe.ProcessJob(context.Background(), w.job))
+
+	w.pg.AssertNotCalled(t, "GetLogFile", mock.Anything, mock.Anything, mock.Anything)
+	w.ch.AssertNotCalled(t, "DeleteJobEntries", mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, w.completed, "the API published the cancellation")
+}
+
+func TestProcessJob_CancelRequestedBeforeRedelivery(t *testing.T) {
+	// A job whose worker crashed after the request was recorded is cleaned
+	// up by the run it is redelivered to.
+	w := newCancelWorld(domain.JobStatusParsing)
+	running := w.job
+	running.Status = domain.JobStatusStoring
+	now := time.Now()
+	running.CancelRequestedAt = &now
+	w.pg.On("GetJob", mock.Anything, w.job.TenantID, w.job.ID).Return(&running, nil)
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+}
+
+func TestProcessJob_CancelledDuringDownload(t *testing.T) {
+	w := newCancelWorld()
+	w.pipe.SetCancellationPoll(5 * time.Millisecond)
+	w.s3.On("Download", mock.Anything, "logs/test.log").Run(w.blockUntilCancelled).
+		Return(nil, errors.New("download aborted"))
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+	w.jar.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessJob_CancelledDuringJAR(t *testing.T) {
+	// On a fake clock the flag is seen at the first poll after it is raised.
+	w := newCancelWorld()
+	fake := clock.NewFake(time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC))
+	w.pipe.SetClock(fake, clock.NewSequence())
+	w.pipe.SetCancellationPoll(time.Second)
+	w.download()
+	w.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), w.job.JARFlags, w.job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Run(func(args mock.Arguments) {
+			w.redis.requestCancel(w.job)
+			fake.BlockUntil(1)
+			fake.Advance(time.Second)
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(&jar.Result{ExitCode: -1}, errors.New("jar runner: process cancelled: context canceled"))
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+	w.ch.AssertNotCalled(t, "BatchInsertEntries", mock.Anything, mock.Anything)
+	require.NotNil(t, w.completed[0].CompletedAt)
+	assert.Equal(t, fake.Now(), *w.completed[0].CompletedAt)
+}
+
+func TestProcessJob_CancelledDuringIngest(t *testing.T) {
+	w := newCancelWorld()
+	w.pipe.SetCancellationPoll(5 * time.Millisecond)
+	w.download()
+	w.jarRuns()
+	w.ch.On("BatchInsertEntries", mock.Anything, mock.Anything).Run(w.blockUntilCancelled).Return(context.Canceled).Once()
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+	w.ch.AssertNumberOfCalls(t, "BatchInsertEntries", 1)
+	assert.NotContains(t, w.statuses, domain.JobStatusComplete)
+}
+
+func TestProcessJob_CancelSeenInPostgres(t *testing.T) {
+	// The flag was lost: the job itself holds the request.
+	w := newCancelWorld()
+	w.pipe.SetCancellationPoll(time.Millisecond)
+	requested := w.job
+	now := time.Now()
+	requested.CancelRequestedAt = &now
+	w.download()
+	w.pg.On("GetJob", mock.Anything, w.job.TenantID, w.job.ID).Return(&requested, nil)
+	w.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), w.job.JARFlags, w.job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(&jar.Result{ExitCode: -1}, errors.New("jar runner: process cancelled: context canceled"))
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+}
+
+func TestProcessJob_CancelledBeforeStoring(t *testing.T) {
+	// Without polling, the request is seen when the job moves to storing.
+	w := newCancelWorld(domain.JobStatusStoring)
+	w.download()
+	w.jarRuns()
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+	w.ch.AssertNotCalled(t, "BatchInsertEntries", mock.Anything, mock.Anything)
+}
+
+func TestProcessJob_CancelWinsOverCompletion(t *testing.T) {
+	// The request landed after the last check but before the job was
+	// marked complete: the completion is refused and the job cancelled.
+	w := newCancelWorld(domain.JobStatusComplete)
+	w.download()
+	w.jarRuns()
+	w.insertsSucceed()
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+}
+
+func TestProcessJob_CancelWinsOverFailure(t *testing.T) {
+	w := newCancelWorld(domain.JobStatusFailed)
+	w.s3.On("Download", mock.Anything, "logs/test.log").Return(nil, errors.New("no such key"))
+
+	w.assertCancelled(t, w.pipe.ProcessJob(context.Background(), w.job))
+}
+
+func TestProcessJob_NotCancelledCompletes(t *testing.T) {
+	w := newCancelWorld()
+	w.pipe.SetCancellationPoll(time.Millisecond)
+	w.pg.On("GetJob", mock.Anything, w.job.TenantID, w.job.ID).Return(&w.job, nil).Maybe()
+	w.download()
+	w.jarRuns()
+	w.insertsSucceed()
+
+	require.NoError(t, w.pipe.ProcessJob(context.Background(), w.job))
+	assert.NotContains(t, w.statuses, domain.JobStatusCancelled)
+	w.ch.AssertNotCalled(t, "DeleteJobEntries", mock.Anything, mock.Anything, mock.Anything)
+}
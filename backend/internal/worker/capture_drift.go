@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/compare"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// SetCaptureDriftAlerts publishes a capture drift alert for every job whose
+// capture drifted from its reference capture. Drift is recorded with the
+// job either way.
+func (p *Pipeline) SetCaptureDriftAlerts(on bool) {
+	p.driftAlerts = on
+}
+
+// captureProfile describes how job's capture was taken from the JAR flags,
+// the log types the capture holds, its log range and its captured files.
+// Without section presence a log type is held when the JAR counted entries
+// of it.
+func captureProfile(job *domain.AnalysisJob, stats domain.GeneralStatistics, files int) *domain.CaptureProfile {
+	profile := &domain.CaptureProfile{JARFlags: job.JARFlags, FileCount: files}
+	if s := job.Sections; s != nil {
+		profile.API, profile.SQL, profile.Filter, profile.Escalation = s.API.Present, s.SQL.Present, s.Filter.Present, s.Escalation.Present
+	} else {
+		profile.API, profile.SQL, profile.Filter, profile.Escalation = stats.APICount > 0, stats.SQLCount > 0, stats.FilterCount > 0, stats.EscCount > 0
+	}
+	if from, to := stats.LogStart.Time, stats.LogEnd.Time; !from.IsZero() && to.After(from) {
+		profile.DurationMS = to.Sub(from).Milliseconds()
+	}
+	return profile
+}
+
+// recordCaptureDrift records how the job's capture was taken and checks it
+// against the tenant's reference capture, recording the differences as
+// capture drift. Failures are logged and otherwise ignored.
+func (p *Pipeline) recordCaptureDrift(ctx context.Context, job *domain.AnalysisJob, stats domain.GeneralStatistics, files int) {
+	logger := slog.With("job_id", job.ID, "tenant_id", job.TenantID)
+	profile := captureProfile(job, stats, files)
+
+	var drift *domain.CaptureDrift
+	ref, kind, err := p.pg.GetCaptureReference(ctx, job.TenantID, job.ID)
+	if err != nil {
+		logger.Warn("capture reference lookup failed (non-fatal)", "error", err)
+	} else if ref != nil {
+		drift = &domain.CaptureDrift{
+			ReferenceJobID: ref.ID,
+			Reference:      kind,
+			Warnings:       compare.CaptureDrift(compare.ProfileOf(ref), profile, kind+" capture"),
+			CheckedAt:      domain.NewTimestamp(p.now()),
+		}
+	}
+
+	if err := p.pg.UpdateJobCaptureDrift(ctx, job.TenantID, job.ID, profile, drift); err != nil {
+		logger.Warn("failed to record capture drift", "error", err)
+		return
+	}
+	job.CaptureProfile, job.CaptureDrift = profile, drift
+	if !drift.Drifted() {
+		return
+	}
+
+	messages := make([]string, len(drift.Warnings))
+	for i, w := range drift.Warnings {
+		messages[i] = w.Message
+	}
+	logger.Info("capture drifted from reference", "reference_job_id", ref.ID, "reference", kind, "warnings", messages)
+	p.recordEvent(*job, domain.JobEventWarning, "post_process", "capture drifted from the "+kind+" capture",
+		map[string]any{"reference_job_id": ref.ID.String(), "warnings": messages})
+	if p.driftAlerts && p.nats != nil {
+		event := domain.CaptureDriftEvent{TenantID: job.TenantID, JobID: job.ID, Drift: *drift}
+		if err := p.nats.PublishCaptureDrift(ctx, job.TenantID.String(), event); err != nil {
+			logger.Warn("failed to publish capture drift alert", "error", err)
+		}
+	}
+}
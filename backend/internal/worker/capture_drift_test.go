@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestCaptureProfile(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	stats := domain.GeneralStatistics{
+		APICount: 10, SQLCount: 0, FilterCount: 4, EscCount: 1,
+		LogStart: domain.NewTimestamp(start),
+		LogEnd:   domain.NewTimestamp(start.Add(90 * time.Minute)),
+	}
+
+	t.Run("from counts", func(t *testing.T) {
+		job := newTestJob()
+		p := captureProfile(&job, stats, 3)
+		assert.Equal(t, &domain.CaptureProfile{
+			JARFlags: job.JARFlags, API: true, Filter: true, Escalation: true,
+			DurationMS: (90 * time.Minute).Milliseconds(), FileCount: 3,
+		}, p)
+	})
+
+	t.Run("from section presence", func(t *testing.T) {
+		job := newTestJob()
+		job.Sections = &domain.SectionPresence{SQL: domain.LogTypePresence{Present: true}}
+		p := captureProfile(&job, stats, 1)
+		assert.False(t, p.API)
+		assert.True(t, p.SQL)
+	})
+
+	t.Run("unknown log range", func(t *testing.T) {
+		job := newTestJob()
+		assert.Zero(t, captureProfile(&job, domain.GeneralStatistics{}, 1).DurationMS)
+	})
+}
+
+func TestRecordCaptureDrift(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	stats := domain.GeneralStatistics{
+		APICount: 10, SQLCount: 5, FilterCount: 4, EscCount: 1,
+		LogStart: domain.NewTimestamp(start),
+		LogEnd:   domain.NewTimestamp(start.Add(time.Hour)),
+	}
+	reference := &domain.AnalysisJob{
+		ID: newTestJob().ID,
+		CaptureProfile: &domain.CaptureProfile{
+			API: true, SQL: false, Filter: true, Escalation: true,
+			DurationMS: time.Hour.Milliseconds(), FileCount: 1,
+		},
+	}
+
+	t.Run("drift is recorded and published", func(t *testing.T) {
+		job := newTestJob()
+		reference.JARFlags = job.JARFlags
+		pg := &testutil.MockPostgresStore{}
+		ns := &testutil.MockNATSStreamer{}
+		pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(reference, domain.CaptureReferenceBaseline, nil)
+		pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.CaptureProfile"), mock.MatchedBy(func(d *domain.CaptureDrift) bool {
+			return d != nil && d.ReferenceJobID == reference.ID && d.Reference == domain.CaptureReferenceBaseline && len(d.Warnings) == 1
+		})).Return(nil)
+		ns.On("PublishCaptureDrift", mock.Anything, job.TenantID.String(), mock.MatchedBy(func(e domain.CaptureDriftEvent) bool {
+			return e.JobID == job.ID && e.Drift.Drifted()
+		})).Return(nil)
+
+		p := NewPipeline(pg, nil, nil, nil, ns, nil, nil)
+		p.SetCaptureDriftAlerts(true)
+		p.recordCaptureDrift(context.Background(), &job, stats, 1)
+
+		require.NotNil(t, job.CaptureDrift)
+		require.Len(t, job.CaptureDrift.Warnings, 1)
+		assert.Equal(t, "SQL logging present in this capture but absent in the baseline capture", job.CaptureDrift.Warnings[0].Message)
+		assert.NotNil(t, job.CaptureProfile)
+		pg.AssertExpectations(t)
+		ns.AssertExpectations(t)
+	})
+
+	t.Run("alerts off", func(t *testing.T) {
+		job := newTestJob()
+		reference.JARFlags = job.JARFlags
+		pg := &testutil.MockPostgresStore{}
+		ns := &testutil.MockNATSStreamer{}
+		pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(reference, domain.CaptureReferencePrevious, nil)
+		pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.Anything, mock.Anything).Return(nil)
+
+		p := NewPipeline(pg, nil, nil, nil, ns, nil, nil)
+		p.recordCaptureDrift(context.Background(), &job, stats, 1)
+
+		assert.True(t, job.CaptureDrift.Drifted())
+		ns.AssertNotCalled(t, "PublishCaptureDrift", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no previous analysis", func(t *testing.T) {
+		job := newTestJob()
+		pg := &testutil.MockPostgresStore{}
+		pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(nil, "", nil)
+		pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.CaptureProfile"), (*domain.CaptureDrift)(nil)).Return(nil)
+
+		p := NewPipeline(pg, nil, nil, nil, nil, nil, nil)
+		p.SetCaptureDriftAlerts(true)
+		p.recordCaptureDrift(context.Background(), &job, stats, 1)
+
+		assert.NotNil(t, job.CaptureProfile)
+		assert.Nil(t, job.CaptureDrift)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("store failure is non-fatal", func(t *testing.T) {
+		job := newTestJob()
+		pg := &testutil.MockPostgresStore{}
+		pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(nil, "", nil)
+		pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.Anything, mock.Anything).Return(errors.New("db down"))
+
+		p := NewPipeline(pg, nil, nil, nil, nil, nil, nil)
+		p.recordCaptureDrift(context.Background(), &job, stats, 1)
+
+		assert.Nil(t, job.CaptureProfile)
+	})
+}
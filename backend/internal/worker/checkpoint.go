@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// JARCheckpointKey returns the object storage key of the JAR report kept
+// while an analysis is in progress, from where a resumed run reads it
+// instead of running the JAR again.
+func JARCheckpointKey(tenantID, jobID string) string {
+	return path.Join("tenants", tenantID, "jar-output", jobID+".checkpoint")
+}
+
+// SetCheckpoints makes the pipeline save the progress of each job as it
+// goes, so that a job redelivered after a worker crash resumes where the
+// crashed run stopped.
+func (p *Pipeline) SetCheckpoints(enabled bool) {
+	p.checkpoints = enabled
+}
+
+// checkpointer saves the progress of one run of a job and tells which of
+// its work an earlier run already did. A nil checkpointer saves nothing and
+// lets every stage run.
+type checkpointer struct {
+	p      *Pipeline
+	job    domain.AnalysisJob
+	cp     *domain.JobCheckpoint
+	logger *slog.Logger
+
+	// resumed is set when cp was saved by an earlier run.
+	resumed bool
+
+	// batch is the index of the next batch of entries offered for insert;
+	// skipped counts those an earlier run inserted.
+	batch   int
+	skipped int
+}
+
+// loadCheckpoint returns the checkpointer of a run of job, resuming the
+// checkpoint of an earlier run unless the job is to be processed from
+// scratch. It returns nil when checkpoints are off.
+func (p *Pipeline) loadCheckpoint(ctx context.Context, job domain.AnalysisJob, logger *slog.Logger) *checkpointer {
+	if !p.checkpoints {
+		return nil
+	}
+	c := &checkpointer{
+		p:      p,
+		job:    job,
+		cp:     &domain.JobCheckpoint{JobID: job.ID, TenantID: job.TenantID, RunID: p.newID()},
+		logger: logger,
+	}
+	if job.FullReprocess {
+		p.clearCheckpoint(ctx, job, logger)
+		return c
+	}
+
+	cp, err := p.pg.GetJobCheckpoint(ctx, job.TenantID, job.ID)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			logger.Warn("job checkpoint not available, processing from scratch", "error", err)
+		}
+		return c
+	}
+	c.cp, c.resumed = cp, true
+	logger.Info("resuming job from checkpoint", "stage", cp.Stage, "next_batch", cp.NextBatch)
+	p.recordEvent(job, domain.JobEventRetry, "resume", "resuming from the checkpoint of an interrupted run",
+		map[string]any{"stage": cp.Stage, "next_batch": cp.NextBatch, "cache_sections": cp.CacheSections})
+	return c
+}
+
+// clearCheckpoint deletes the checkpoint of job and the JAR report it kept.
+func (p *Pipeline) clearCheckpoint(ctx context.Context, job domain.AnalysisJob, logger *slog.Logger) {
+	if err := p.pg.DeleteJobCheckpoint(ctx, job.TenantID, job.ID); err != nil {
+		logger.Warn("failed to delete job checkpoint", "error", err)
+	}
+	key := JARCheckpointKey(job.TenantID.String(), job.ID.String())
+	if err := p.s3.Delete(ctx, key); err != nil {
+		logger.Warn("failed to delete checkpointed JAR output", "s3_key", key, "error", err)
+	}
+}
+
+// save stores the checkpoint. Failing to does not fail the job: a crash
+// then only resumes from an earlier point.
+func (c *checkpointer) save(ctx context.Context) {
+	if err := c.p.pg.SaveJobCheckpoint(ctx, c.cp); err != nil {
+		c.logger.Warn("failed to save job checkpoint", "stage", c.cp.Stage, "next_batch", c.cp.NextBatch, "error", err)
+	}
+}
+
+// jarOutput returns the JAR report an earlier run kept and the report
+// format it was produced in. ok is false when the JAR has to run.
+func (c *checkpointer) jarOutput(ctx context.Context) (result *jar.Result, format string, ok bool) {
+	if c == nil || !c.resumed || c.cp.JAROutputKey == "" {
+		return nil, "", false
+	}
+	reader, err := c.p.s3.Download(ctx, c.cp.JAROutputKey)
+	if err != nil {
+		c.logger.Warn("checkpointed JAR output not available, running the JAR again", "s3_key", c.cp.JAROutputKey, "error", err)
+		return nil, "", false
+	}
+	defer reader.Close()
+	stdout, err := io.ReadAll(reader)
+	if err != nil {
+		c.logger.Warn("checkpointed JAR output not readable, running the JAR again", "s3_key", c.cp.JAROutputKey, "error", err)
+		return nil, "", false
+	}
+	return &jar.Result{Stdout: string(stdout)}, c.cp.OutputFormat, true
+}
+
+// jarDone keeps the JAR report produced in format and records the JAR
+// stage complete.
+func (c *checkpointer) jarDone(ctx context.Context, stdout, format string) {
+	if c == nil {
+		return
+	}
+	key := JARCheckpointKey(c.job.TenantID.String(), c.job.ID.String())
+	if err := c.p.s3.Upload(ctx, key, strings.NewReader(stdout), int64(len(stdout))); err != nil {
+		c.logger.Warn("failed to checkpoint JAR output", "s3_key", key, "error", err)
+		return
+	}
+	c.cp.Stage = domain.CheckpointStageJAR
+	c.cp.JAROutputKey = key
+	c.cp.OutputFormat = format
+	c.save(ctx)
+}
+
+// parsed records the report parsed and the analysis sections cached.
+func (c *checkpointer) parsed(ctx context.Context, sections []string) {
+	if c == nil {
+		return
+	}
+	c.cp.CacheSections = sections
+	if c.cp.Stage == domain.CheckpointStageJAR {
+		c.cp.Stage = domain.CheckpointStageParse
+	}
+	c.save(ctx)
+}
+
+// ingested records every batch of entries inserted.
+func (c *checkpointer) ingested(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.cp.Stage = domain.CheckpointStageIngest
+	c.save(ctx)
+}
+
+// insertBatch inserts the next batch of entries of the job. Batches an
+// earlier run inserted are skipped; the others are sent with a
+// deduplication token of their own, so that the batch a crash left
+// unconfirmed can be sent again, and are recorded once stored. Every batch
+// offered takes an index, even when it is empty, so that a resumed run
+// numbers its batches as the crashed one did.
+func (p *Pipeline) insertBatch(ctx context.Context, c *checkpointer, batch []domain.LogEntry) error {
+	if c == nil {
+		if len(batch) == 0 {
+			return nil
+		}
+		return p.ch.BatchInsertEntries(ctx, batch)
+	}
+	index := c.batch
+	c.batch++
+	if index < c.cp.NextBatch {
+		c.skipped++
+		return nil
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	token := fmt.Sprintf("%s-%d", c.cp.RunID, index)
+	if err := p.ch.BatchInsertEntries(storage.WithInsertToken(ctx, token), batch); err != nil {
+		return err
+	}
+	c.cp.NextBatch = index + 1
+	c.cp.LastBatchToken = token
+	c.save(ctx)
+	return nil
+}
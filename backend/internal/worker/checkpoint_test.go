@@ -0,0 +1,369 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// workerCrash is the panic of a simulated worker crash.
+type workerCrash struct{}
+
+// checkpointWorld holds what outlives a worker: the checkpoint and object
+// storage. Its runs process the same job with fresh mocks, as the worker
+// the job is redelivered to after a crash would.
+type checkpointWorld struct {
+	job     domain.AnalysisJob
+	capture string
+
+	mu         sync.Mutex
+	checkpoint *domain.JobCheckpoint
+	objects    map[string]string
+}
+
+// checkpointRun is what one run of the job did.
+type checkpointRun struct {
+	ch      *testutil.MockClickHouseStore
+	jar     *MockJARRunner
+	crashed bool
+	tokens  []string // of the batches inserted, in order
+	entries int      // inserted
+}
+
+// crashPoint decides whether the worker crashes after saving cp, or after
+// inserting the batch of the given token when cp is nil.
+type crashPoint func(cp *domain.JobCheckpoint, token string) bool
+
+// newCheckpointWorld sets up a job whose capture holds 12,000 API calls,
+// inserted in batches of 5,000, 5,000 and 2,000.
+func newCheckpointWorld() *checkpointWorld {
+	base := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	var lines []string
+	for i := 0; i < 12000; i++ {
+		lines = append(lines, fmt.Sprintf("<API > <TrID: tr-%d> <TID: 0000000100> <RPC ID: 0000005000> <Queue: Fast        > <Client-RPC: 100200   > <USER: Demo> <Overlay-Group: 1         > /* %s */ +GE HPD:Help Desk",
+			i, base.Add(time.Duration(i)*time.Second).Format("Mon Jan 02 2006 15:04:05.0000")))
+	}
+	return &checkpointWorld{
+		job:     newTestJob(),
+		capture: strings.Join(lines, "\n") + "\n",
+		objects: map[string]string{},
+	}
+}
+
+// checkpointPG keeps the checkpoint of the world and crashes the worker
+// after the saves crash picks.
+type checkpointPG struct {
+	*testutil.MockPostgresStore
+	w     *checkpointWorld
+	crash func(cp *domain.JobCheckpoint, token string)
+}
+
+func (s *checkpointPG) GetJobCheckpoint(_ context.Context, _, jobID uuid.UUID) (*domain.JobCheckpoint, error) {
+	if cp := s.w.saved(); cp != nil {
+		return cp, nil
+	}
+	return nil, fmt.Errorf("postgres: job checkpoint not found: %s", jobID)
+}
+
+func (s *checkpointPG) SaveJobCheckpoint(_ context.Context, cp *domain.JobCheckpoint) error {
+	saved := *cp
+	saved.CacheSections = append([]string(nil), cp.CacheSections...)
+	s.w.mu.Lock()
+	s.w.checkpoint = &saved
+	s.w.mu.Unlock()
+	s.crash(&saved, "")
+	return nil
+}
+
+func (s *checkpointPG) DeleteJobCheckpoint(context.Context, uuid.UUID, uuid.UUID) error {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	s.w.checkpoint = nil
+	return nil
+}
+
+// checkpointCaptureKey is the key of the uploaded capture.
+const checkpointCaptureKey = "logs/capture.log"
+
+// checkpointS3 keeps the objects of the world; the capture is downloaded
+// from the embedded mock.
+type checkpointS3 struct {
+	*testutil.MockObjectStorage
+	w *checkpointWorld
+}
+
+func (s *checkpointS3) Upload(_ context.Context, key string, r io.Reader, _ int64) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	s.w.objects[key] = string(b)
+	return nil
+}
+
+func (s *checkpointS3) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.w.mu.Lock()
+	obj, ok := s.w.objects[key]
+	s.w.mu.Unlock()
+	if !ok {
+		if key == checkpointCaptureKey {
+			return s.MockObjectStorage.Download(ctx, key)
+		}
+		return nil, fmt.Errorf("s3: object not found: %s", key)
+	}
+	return io.NopCloser(strings.NewReader(obj)), nil
+}
+
+func (s *checkpointS3) Delete(_ context.Context, key string) error {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	delete(s.w.objects, key)
+	return nil
+}
+
+func (w *checkpointWorld) saved() *domain.JobCheckpoint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.checkpoint == nil {
+		return nil
+	}
+	cp := *w.checkpoint
+	return &cp
+}
+
+// run processes the job once with checkpoints on, until it completes or
+// crash says the worker crashed.
+func (w *checkpointWorld) run(t *testing.T, crash crashPoint) *checkpointRun {
+	t.Helper()
+	job := w.job
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{} // for the capture
+	run := &checkpointRun{ch: ch, jar: &MockJARRunner{}}
+	crashes := func(cp *domain.JobCheckpoint, token string) {
+		if crash != nil && crash(cp, token) {
+			panic(workerCrash{})
+		}
+	}
+
+	file := &domain.LogFile{ID: job.FileID, TenantID: job.TenantID, S3Key: checkpointCaptureKey, SizeBytes: int64(len(w.capture))}
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobStatus"), (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("UpdateJobRestarts", mock.Anything, job.TenantID, job.ID, mock.Anything).Return(nil).Maybe()
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
+	pg.On("ListIngestionFilterRules", mock.Anything, job.TenantID).Return([]domain.IngestionFilterRule{}, nil).Maybe()
+	pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(nil, "", nil).Maybe()
+	pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.Anything, mock.Anything).Return(nil).Maybe()
+	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
+	expectReconciliation(pg, ch, job, validJARCounts)
+	ch.On("BuildJobRollup", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil)
+	// The post-processing reads are not what these tests are about.
+	ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil, errors.New("not needed")).Maybe()
+	ch.On("GetFocusMetrics", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything, mock.Anything).Return(nil, errors.New("not needed")).Maybe()
+	ch.On("GetJobVocabulary", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything).Return(nil, errors.New("not needed")).Maybe()
+
+	s3.On("Download", mock.Anything, file.S3Key).Return(io.NopCloser(strings.NewReader(w.capture)), nil)
+
+	ch.On("BatchInsertEntries", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			token := storage.InsertToken(args.Get(0).(context.Context))
+			run.tokens = append(run.tokens, token)
+			run.entries += len(args.Get(1).([]domain.LogEntry))
+			crashes(nil, token)
+		}).Return(nil)
+	run.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: validJAROutput}, nil)
+
+	p := NewPipeline(&checkpointPG{MockPostgresStore: pg, w: w, crash: crashes}, ch, &checkpointS3{MockObjectStorage: s3, w: w}, nil, nats, run.jar, nil)
+	p.SetCheckpoints(true)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(workerCrash); !ok {
+					panic(r)
+				}
+				run.crashed = true
+			}
+		}()
+		require.NoError(t, p.ProcessJob(context.Background(), job))
+	}()
+	return run
+}
+
+// TestProcessJob_ResumesFromCheckpoint crashes the worker at each stage
+// boundary of a job, then runs the job again: the second run does only
+// what the crashed one left undone, and the job ends with each batch
+// inserted under a single token.
+func TestProcessJob_ResumesFromCheckpoint(t *testing.T) {
+	tests := []struct {
+		name  string
+		crash crashPoint
+		// What the resumed run does.
+		jarRuns int
+		tokens  []int // indexes of the batches inserted
+		entries int
+	}{
+		{
+			name: "after the JAR",
+			crash: func(cp *domain.JobCheckpoint, _ string) bool {
+				return cp != nil && cp.Stage == domain.CheckpointStageJAR
+			},
+			tokens:  []int{0, 1, 2},
+			entries: 12000,
+		},
+		{
+			name: "after parsing",
+			crash: func(cp *domain.JobCheckpoint, _ string) bool {
+				return cp != nil && cp.Stage == domain.CheckpointStageParse && cp.NextBatch == 0
+			},
+			tokens:  []int{0, 1, 2},
+			entries: 12000,
+		},
+		{
+			name:    "after a batch",
+			crash:   func(cp *domain.JobCheckpoint, _ string) bool { return cp != nil && cp.NextBatch == 1 },
+			tokens:  []int{1, 2},
+			entries: 7000,
+		},
+		{
+			// The second batch is stored but not recorded: it is sent again
+			// with its token, which ClickHouse drops.
+			name:    "before a batch is recorded",
+			crash:   func(cp *domain.JobCheckpoint, token string) bool { return cp == nil && strings.HasSuffix(token, "-1") },
+			tokens:  []int{1, 2},
+			entries: 7000,
+		},
+		{
+			name: "after ingestion",
+			crash: func(cp *domain.JobCheckpoint, _ string) bool {
+				return cp != nil && cp.Stage == domain.CheckpointStageIngest
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newCheckpointWorld()
+			first := w.run(t, tt.crash)
+			require.True(t, first.crashed)
+			first.jar.AssertNumberOfCalls(t, "Run", 1)
+			cp := w.saved()
+			require.NotNil(t, cp)
+			assert.Equal(t, JARCheckpointKey(w.job.TenantID.String(), w.job.ID.String()), cp.JAROutputKey)
+			assert.Contains(t, w.objects, cp.JAROutputKey)
+
+			resumed := w.run(t, nil)
+			require.False(t, resumed.crashed)
+			resumed.jar.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			resumed.ch.AssertNumberOfCalls(t, "BatchInsertEntries", len(tt.tokens))
+			var want []string
+			for _, i := range tt.tokens {
+				want = append(want, fmt.Sprintf("%s-%d", cp.RunID, i))
+			}
+			assert.Equal(t, want, resumed.tokens)
+			assert.Equal(t, tt.entries, resumed.entries)
+			// The cheap stages run again.
+			resumed.ch.AssertCalled(t, "BuildJobRollup", mock.Anything, w.job.TenantID.String(), w.job.ID.String())
+
+			// Every batch was stored once, as far as ClickHouse is concerned.
+			stored := map[string]bool{}
+			for _, token := range append(first.tokens, resumed.tokens...) {
+				stored[token] = true
+			}
+			assert.Len(t, stored, 3)
+
+			// The completed job leaves no checkpoint behind.
+			assert.Nil(t, w.saved())
+			assert.Empty(t, w.objects)
+		})
+	}
+}
+
+func TestProcessJob_Checkpoints(t *testing.T) {
+	t.Run("a job without checkpoint runs in full", func(t *testing.T) {
+		w := newCheckpointWorld()
+		run := w.run(t, nil)
+		require.False(t, run.crashed)
+		run.jar.AssertNumberOfCalls(t, "Run", 1)
+		require.Len(t, run.tokens, 3)
+		assert.Equal(t, 12000, run.entries)
+		assert.Nil(t, w.saved())
+		assert.Empty(t, w.objects)
+	})
+
+	t.Run("a full reprocess discards the checkpoint", func(t *testing.T) {
+		w := newCheckpointWorld()
+		first := w.run(t, func(cp *domain.JobCheckpoint, _ string) bool { return cp != nil && cp.NextBatch == 2 })
+		require.True(t, first.crashed)
+		runID := w.saved().RunID
+
+		w.job.FullReprocess = true
+		run := w.run(t, func(cp *domain.JobCheckpoint, _ string) bool {
+			return cp != nil && cp.Stage == domain.CheckpointStageJAR
+		})
+		require.True(t, run.crashed)
+		run.jar.AssertNumberOfCalls(t, "Run", 1)
+		assert.NotEqual(t, runID, w.saved().RunID, "the batches are inserted under new tokens")
+		assert.Zero(t, w.saved().NextBatch)
+	})
+
+	t.Run("a lost JAR output runs the JAR again", func(t *testing.T) {
+		w := newCheckpointWorld()
+		first := w.run(t, func(cp *domain.JobCheckpoint, _ string) bool { return cp != nil && cp.NextBatch == 1 })
+		require.True(t, first.crashed)
+		clear(w.objects)
+
+		run := w.run(t, nil)
+		require.False(t, run.crashed)
+		run.jar.AssertNumberOfCalls(t, "Run", 1)
+		assert.Len(t, run.tokens, 2, "the stored batch is still skipped")
+	})
+}
+
+func TestJARCheckpointKey(t *testing.T) {
+	assert.Equal(t, "tenants/t1/jar-output/j1.checkpoint", JARCheckpointKey("t1", "j1"))
+}
+
+// The fallback to cached sections while ClickHouse is down is for reads
+// only: an insert that cannot reach ClickHouse fails the run, and the
+// checkpoint does not move past the batch.
+func TestInsertBatch_ClickHouseUnavailable(t *testing.T) {
+	down := fmt.Errorf("clickhouse: batch insert: %w", syscall.ECONNREFUSED)
+	ch := new(testutil.MockClickHouseStore)
+	ch.On("BatchInsertEntries", mock.Anything, mock.Anything).Return(down)
+	p := NewPipeline(nil, ch, nil, nil, nil, nil, nil)
+	c := &checkpointer{p: p, cp: &domain.JobCheckpoint{RunID: uuid.New()}, logger: slog.Default()}
+
+	for _, cp := range []*checkpointer{nil, c} {
+		err := p.insertBatch(context.Background(), cp, []domain.LogEntry{{LineNumber: 1}})
+		require.ErrorIs(t, err, syscall.ECONNREFUSED)
+		assert.True(t, storage.IsUnavailable(err))
+	}
+	assert.Equal(t, 0, c.cp.NextBatch)
+	assert.Empty(t, c.cp.LastBatchToken)
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// unknownClientIP is how the JAR groups API calls that came from no address.
+const unknownClientIP = "null"
+
+// clientInfo is the client program and address of an API call.
+type clientInfo struct {
+	client, ip string
+}
+
+// threadRPC is the client of the RPC a thread is working on.
+type threadRPC struct {
+	rpcID string
+	clientInfo
+}
+
+// clientStamper fills in the client and client_ip of ingested entries.
+//
+// AR Server names the client only on the start line of an API call. The
+// end line and the SQL, filter and escalation lines logged by the thread
+// for the same RPC inherit it. API calls whose line names no client take
+// it from the JAR's client aggregates: by the line number of the calls
+// the aggregates list, or from the only client or address the capture saw.
+type clientStamper struct {
+	byLine        map[uint32]clientInfo
+	defaultClient string
+	defaultIP     string
+	threads       map[string]threadRPC
+}
+
+// newClientStamper returns a stamper backed by the client aggregates of
+// the JAR report, which may be nil.
+func newClientStamper(agg *domain.JARAggregatesResponse) *clientStamper {
+	s := &clientStamper{
+		byLine:  make(map[uint32]clientInfo),
+		threads: make(map[string]threadRPC),
+	}
+	if agg == nil {
+		return s
+	}
+	if t := agg.APIByClient; t != nil {
+		for _, g := range t.Groups {
+			s.indexLines(g, func(c *clientInfo) { c.client = g.EntityName })
+		}
+		if len(t.Groups) == 1 {
+			s.defaultClient = t.Groups[0].EntityName
+		}
+	}
+	if t := agg.APIByClientIP; t != nil {
+		for _, g := range t.Groups {
+			if g.EntityName == unknownClientIP {
+				continue
+			}
+			s.indexLines(g, func(c *clientInfo) { c.ip = g.EntityName })
+		}
+		if len(t.Groups) == 1 && t.Groups[0].EntityName != unknownClientIP {
+			s.defaultIP = t.Groups[0].EntityName
+		}
+	}
+	return s
+}
+
+// indexLines records the calls of an aggregate group by the lines the JAR
+// reports for its fastest and slowest call.
+func (s *clientStamper) indexLines(g domain.JARAggregateGroup, set func(*clientInfo)) {
+	for _, r := range g.Rows {
+		for _, line := range []int{r.MinLine, r.MaxLine} {
+			if line <= 0 {
+				continue
+			}
+			c := s.byLine[uint32(line)]
+			set(&c)
+			s.byLine[uint32(line)] = c
+		}
+	}
+}
+
+// stamp fills in the client of the entries of a batch. Batches must be
+// passed in capture order.
+func (s *clientStamper) stamp(batch []domain.LogEntry) {
+	for i := range batch {
+		e := &batch[i]
+		if cur, ok := s.threads[e.ThreadID]; ok && e.ThreadID != "" && cur.rpcID == e.RPCID {
+			fillClient(e, cur.clientInfo)
+		}
+		if e.LogType == domain.LogTypeAPI {
+			fillClient(e, s.byLine[e.LineNumber])
+			fillClient(e, clientInfo{client: s.defaultClient, ip: s.defaultIP})
+		}
+		if e.ThreadID != "" && (e.Client != "" || e.ClientIP != "") {
+			s.threads[e.ThreadID] = threadRPC{rpcID: e.RPCID, clientInfo: clientInfo{client: e.Client, ip: e.ClientIP}}
+		}
+	}
+}
+
+// fillClient sets the client fields of e that are still empty.
+func fillClient(e *domain.LogEntry, c clientInfo) {
+	if e.Client == "" {
+		e.Client = c.client
+	}
+	if e.ClientIP == "" {
+		e.ClientIP = c.ip
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestClientStamper(t *testing.T) {
+	agg := &domain.JARAggregatesResponse{
+		APIByClient: &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{
+			{EntityName: "Approval Server", Rows: []domain.JARAggregateRow{{OperationType: "SE", MinLine: 7211, MaxLine: 8620}}},
+			{EntityName: "Assignment Engine", Rows: []domain.JARAggregateRow{{OperationType: "GE", MinLine: 5971, MaxLine: 5971}}},
+		}},
+		APIByClientIP: &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{
+			{EntityName: "10.11.19.4", Rows: []domain.JARAggregateRow{{OperationType: "SE", MinLine: 7211, MaxLine: 8620}}},
+			{EntityName: "null", Rows: []domain.JARAggregateRow{{OperationType: "GE", MinLine: 5971, MaxLine: 5971}}},
+		}},
+	}
+	s := newClientStamper(agg)
+
+	first := []domain.LogEntry{
+		// The start line names its client; the RPC's other lines inherit it.
+		{LineNumber: 10, LogType: domain.LogTypeAPI, ThreadID: "T1", RPCID: "100", Client: "Mid-tier", ClientIP: "10.1.2.3"},
+		{LineNumber: 11, LogType: domain.LogTypeSQL, ThreadID: "T1", RPCID: "100"},
+		// Lines without a client fall back to the JAR aggregates.
+		{LineNumber: 7211, LogType: domain.LogTypeAPI, ThreadID: "T2", RPCID: "200"},
+		{LineNumber: 5971, LogType: domain.LogTypeAPI, ThreadID: "T3", RPCID: "300"},
+	}
+	second := []domain.LogEntry{
+		// Inheritance carries across batches.
+		{LineNumber: 12, LogType: domain.LogTypeAPI, ThreadID: "T1", RPCID: "100"},
+		{LineNumber: 7212, LogType: domain.LogTypeFilter, ThreadID: "T2", RPCID: "200"},
+		// A new RPC on the thread does not.
+		{LineNumber: 13, LogType: domain.LogTypeSQL, ThreadID: "T1", RPCID: "101"},
+		// Neither named nor listed by the JAR.
+		{LineNumber: 14, LogType: domain.LogTypeAPI, ThreadID: "T4", RPCID: "400"},
+	}
+	s.stamp(first)
+	s.stamp(second)
+
+	got := func(e domain.LogEntry) [2]string { return [2]string{e.Client, e.ClientIP} }
+	assert.Equal(t, [2]string{"Mid-tier", "10.1.2.3"}, got(first[0]))
+	assert.Equal(t, [2]string{"Mid-tier", "10.1.2.3"}, got(first[1]))
+	assert.Equal(t, [2]string{"Approval Server", "10.11.19.4"}, got(first[2]))
+	assert.Equal(t, [2]string{"Assignment Engine", ""}, got(first[3]), "calls from no address keep an empty client_ip")
+	assert.Equal(t, [2]string{"Mid-tier", "10.1.2.3"}, got(second[0]))
+	assert.Equal(t, [2]string{"Approval Server", "10.11.19.4"}, got(second[1]))
+	assert.Equal(t, [2]string{"", ""}, got(second[2]))
+	assert.Equal(t, [2]string{"", ""}, got(second[3]))
+}
+
+func TestClientStamper_SingleClient(t *testing.T) {
+	s := newClientStamper(&domain.JARAggregatesResponse{
+		APIByClient:   &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{{EntityName: "Mid-tier"}}},
+		APIByClientIP: &domain.JARAggregateTable{Groups: []domain.JARAggregateGroup{{EntityName: "10.1.2.3"}}},
+	})
+	batch := []domain.LogEntry{
+		{LineNumber: 1, LogType: domain.LogTypeAPI, ThreadID: "T1", RPCID: "1"},
+		{LineNumber: 2, LogType: domain.LogTypeSQL, ThreadID: "T1", RPCID: "1"},
+		{LineNumber: 3, LogType: domain.LogTypeEscalation, ThreadID: "T9", RPCID: "9"},
+	}
+	s.stamp(batch)
+
+	assert.Equal(t, "Mid-tier", batch[0].Client)
+	assert.Equal(t, "10.1.2.3", batch[0].ClientIP)
+	assert.Equal(t, "Mid-tier", batch[1].Client)
+	assert.Empty(t, batch[2].Client, "only API calls and their RPCs get the capture's client")
+}
+
+func TestClientStamper_NoAggregates(t *testing.T) {
+	batch := []domain.LogEntry{{LineNumber: 1, LogType: domain.LogTypeAPI, ThreadID: "T1", RPCID: "1"}}
+	newClientStamper(nil).stamp(batch)
+	assert.Empty(t, batch[0].Client)
+	assert.Empty(t, batch[0].ClientIP)
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/compare"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// comparisonExportExt is the object extension of each comparison format.
+var comparisonExportExt = map[string]string{
+	domain.ExportFormatComparisonHTML: "html",
+	domain.ExportFormatComparisonZIP:  "zip",
+}
+
+// ComparisonExportKey builds the tenant-prefixed S3 key of a comparison
+// export. Reports are small and stored uncompressed so that the pre-signed
+// URL opens directly in a browser.
+// Format: tenants/{tenantID}/exports/{exportID}.{html|zip}
+func ComparisonExportKey(tenantID, exportID, format string) string {
+	return path.Join("tenants", tenantID, "exports", exportID+"."+comparisonExportExt[format])
+}
+
+// processComparison renders the comparison of the two analyses named in the
+// export query and uploads it to S3. The export's row count is the number
+// of sections in the report.
+func (e *Exporter) processComparison(ctx context.Context, export domain.SearchExport) error {
+	tenantID := export.TenantID.String()
+	exportID := export.ID.String()
+
+	var q domain.ComparisonExportQuery
+	if err := json.Unmarshal(export.Query, &q); err != nil {
+		return e.failExport(ctx, export, "invalid comparison query: "+err.Error())
+	}
+	sections := q.Sections
+	if len(sections) == 0 {
+		sections = domain.ComparisonSections
+	}
+
+	baseline, err := e.pg.GetJob(ctx, export.TenantID, q.BaselineJobID)
+	if err != nil {
+		return e.failExport(ctx, export, "load baseline analysis: "+err.Error())
+	}
+	candidate, err := e.pg.GetJob(ctx, export.TenantID, q.CandidateJobID)
+	if err != nil {
+		return e.failExport(ctx, export, "load candidate analysis: "+err.Error())
+	}
+
+	cmp, err := compare.NewComparer(e.pg, e.ch).Compare(ctx, export.TenantID, baseline, candidate, sections)
+	if err != nil {
+		return e.failExport(ctx, export, "compare analyses: "+err.Error())
+	}
+	prov, err := e.provenance(ctx, export, baseline, candidate)
+	if err != nil {
+		return e.failExport(ctx, export, "record provenance: "+err.Error())
+	}
+	embedded := prov.Embedded()
+	cmp.Provenance = &embedded
+	cmp.GeneratedAt = domain.NewTimestamp(prov.GeneratedAt)
+	_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, 50, string(domain.ExportStatusRunning), "rendering comparison")
+
+	var buf bytes.Buffer
+	if export.Format == domain.ExportFormatComparisonZIP {
+		err = compare.WriteZIP(&buf, cmp)
+	} else {
+		var html []byte
+		if html, err = compare.RenderHTML(cmp); err == nil {
+			buf.Write(html)
+		}
+	}
+	if err != nil {
+		return e.failExport(ctx, export, "render comparison: "+err.Error())
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	prov.ContentHash = hex.EncodeToString(sum[:])
+	size := int64(buf.Len())
+	key := ComparisonExportKey(tenantID, exportID, export.Format)
+	if err := e.s3.Upload(ctx, key, &buf, size); err != nil {
+		return e.failExport(ctx, export, "upload export: "+err.Error())
+	}
+
+	if err := e.pg.CompleteSearchExport(ctx, export.TenantID, export.ID, key, int64(len(sections)), size, prov); err != nil {
+		return e.failExport(ctx, export, "record export: "+err.Error())
+	}
+	_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, 100, string(domain.ExportStatusComplete),
+		fmt.Sprintf("comparison export complete: %d sections", len(sections)))
+
+	slog.Info("comparison export complete", "export_id", exportID, "tenant_id", tenantID,
+		"size_bytes", size, "s3_key", key)
+	return nil
+}
@@ -0,0 +1,295 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// MinCostModelJobs is how many completed jobs of a tenant and source
+	// type a cost model is fitted on at least. Until then estimates use
+	// the default model.
+	MinCostModelJobs = 5
+
+	// costModelSampleSize is how many of the latest completed jobs of each
+	// tenant and source type a cost model is fitted on.
+	costModelSampleSize = 200
+
+	// SamplingRecommendedRows is the number of rows above which sampling a
+	// capture is advised. The suggested sample rate brings the rows stored
+	// back under it.
+	SamplingRecommendedRows = 50_000_000
+)
+
+// The default cost model: an AR Server log line of about 250 bytes per
+// entry, the JAR at 500 ms per MB and inserts at 40,000 rows a second,
+// which together match the queue estimator's default processing rate.
+const (
+	defaultRowsPerByte        = 1.0 / 250
+	defaultJARMSPerByte       = 500.0 / (1 << 20)
+	defaultInsertMSPerRow     = 0.025
+	defaultStorageBytesPerRow = 60
+)
+
+// DefaultCostModel returns the model estimates of a tenant's files of a
+// source type use until enough of its jobs completed to fit one.
+func DefaultCostModel(tenantID uuid.UUID, sourceType domain.LogSourceType) domain.JobCostModel {
+	return domain.JobCostModel{
+		TenantID:           tenantID,
+		SourceType:         sourceType,
+		Rows:               domain.LinearFit{Slope: defaultRowsPerByte},
+		JARMS:              domain.LinearFit{Slope: defaultJARMSPerByte},
+		InsertMS:           domain.LinearFit{Slope: defaultInsertMSPerRow},
+		StorageBytesPerRow: defaultStorageBytesPerRow,
+	}
+}
+
+// FitCostModel fits the cost model of a tenant's files of a source type on
+// its completed, unsampled jobs by least squares: rows over bytes, JAR time
+// over bytes and insert time over rows. storageBytesPerRow is what a row of
+// the tenant takes in ClickHouse, or 0 when not known. With fewer than
+// MinCostModelJobs jobs the default model is returned.
+func FitCostModel(tenantID uuid.UUID, sourceType domain.LogSourceType, samples []domain.JobCostSample, storageBytesPerRow float64, now time.Time) domain.JobCostModel {
+	model := DefaultCostModel(tenantID, sourceType)
+	if storageBytesPerRow > 0 {
+		model.StorageBytesPerRow = storageBytesPerRow
+	}
+	if len(samples) < MinCostModelJobs {
+		return model
+	}
+
+	bytes := make([]float64, len(samples))
+	rows := make([]float64, len(samples))
+	jarMS := make([]float64, len(samples))
+	insertMS := make([]float64, len(samples))
+	for i, s := range samples {
+		bytes[i] = float64(s.SizeBytes)
+		rows[i] = float64(s.Rows)
+		jarMS[i] = float64(s.JARMS)
+		insertMS[i] = float64(s.InsertMS)
+	}
+	model.Jobs = len(samples)
+	model.Rows = fitLine(bytes, rows, model.Rows)
+	model.JARMS = fitLine(bytes, jarMS, model.JARMS)
+	model.InsertMS = fitLine(rows, insertMS, model.InsertMS)
+	model.FittedAt = now.UTC()
+
+	var sum float64
+	for _, s := range samples {
+		predicted := model.JARMS.At(float64(s.SizeBytes)) + model.InsertMS.At(model.Rows.At(float64(s.SizeBytes)))
+		sum += divergencePct(s.JARMS+s.InsertMS, int64(math.Round(predicted)))
+	}
+	errPct := math.Round(sum/float64(len(samples))*100) / 100
+	model.ErrorPct = &errPct
+	return model
+}
+
+// fitLine fits y over x by least squares. A line that would not grow with
+// x, as when every x is the same, is replaced by the ratio of the sums
+// through the origin, and by fallback when there is nothing to divide.
+func fitLine(xs, ys []float64, fallback domain.LinearFit) domain.LinearFit {
+	n := float64(len(xs))
+	var sumX, sumY, sumXX, sumXY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXX += xs[i] * xs[i]
+		sumXY += xs[i] * ys[i]
+	}
+	if denom := n*sumXX - sumX*sumX; denom > 0 {
+		slope := (n*sumXY - sumX*sumY) / denom
+		if slope > 0 {
+			return domain.LinearFit{Intercept: (sumY - slope*sumX) / n, Slope: slope}
+		}
+	}
+	if sumX > 0 && sumY > 0 {
+		return domain.LinearFit{Slope: sumY / sumX}
+	}
+	return fallback
+}
+
+// EstimateInput is what a cost estimate is computed for: a file of
+// SizeBytes, ingested in full or one trace in SampleRate, for a tenant with
+// the given quotas that holds Analyses analyses. QueueWaitMS is the wait
+// the queue estimator expects.
+type EstimateInput struct {
+	SizeBytes   int64
+	SourceType  domain.LogSourceType
+	SampleRate  int
+	QueueWaitMS int64
+	Quotas      domain.TenantQuotas
+	Analyses    int
+}
+
+// EstimateJob estimates what analysing a file costs under model.
+func EstimateJob(model domain.JobCostModel, in EstimateInput, now time.Time) domain.JobEstimate {
+	size := float64(in.SizeBytes)
+	rows := model.Rows.At(size)
+	stored := rows
+	if in.SampleRate >= domain.MinSampleRate {
+		stored = rows / float64(in.SampleRate)
+	}
+	jarMS := int64(math.Round(model.JARMS.At(size)))
+	insertMS := int64(math.Round(model.InsertMS.At(stored)))
+
+	est := domain.JobEstimate{
+		SizeBytes:    in.SizeBytes,
+		SourceType:   in.SourceType,
+		SampleRate:   in.SampleRate,
+		ProcessingMS: jarMS + insertMS,
+		JARMS:        jarMS,
+		InsertMS:     insertMS,
+		QueueWaitMS:  max(in.QueueWaitMS, 0),
+		Rows:         int64(math.Round(stored)),
+		StorageBytes: int64(math.Round(stored * model.StorageBytesPerRow)),
+		Model:        domain.CostModelDefault,
+		ModelJobs:    model.Jobs,
+		EstimatedAt:  now.UTC(),
+	}
+	if model.Jobs >= MinCostModelJobs {
+		est.Model = domain.CostModelFitted
+	}
+	if in.SampleRate == 0 && rows > SamplingRecommendedRows {
+		est.SamplingRecommended = true
+		rate := int(math.Ceil(rows / SamplingRecommendedRows))
+		est.SuggestedSampleRate = min(max(rate, domain.MinSampleRate), domain.MaxSampleRate)
+	}
+	if limit := in.Quotas.MaxFileBytes; limit > 0 && in.SizeBytes > limit {
+		est.QuotaExceeded = append(est.QuotaExceeded, "max_file_bytes")
+	}
+	if limit := in.Quotas.MaxAnalyses; limit > 0 && in.Analyses >= limit {
+		est.QuotaExceeded = append(est.QuotaExceeded, "max_analyses")
+	}
+	return est
+}
+
+// MeasureAccuracy compares the estimate of a completed job with its cost.
+func MeasureAccuracy(est domain.JobEstimate, s domain.JobCostSample, now time.Time) domain.EstimateAccuracy {
+	processing := s.JARMS + s.InsertMS
+	return domain.EstimateAccuracy{
+		ProcessingMS:       processing,
+		QueueWaitMS:        s.QueueMS,
+		Rows:               s.Rows,
+		ProcessingErrorPct: divergencePct(processing, est.ProcessingMS),
+		QueueWaitErrorPct:  divergencePct(s.QueueMS, est.QueueWaitMS),
+		RowsErrorPct:       divergencePct(s.Rows, est.Rows),
+		RecordedAt:         now.UTC(),
+	}
+}
+
+// JobEstimator estimates the cost of analysing a file of a tenant with the
+// tenant's cost model and the current queue.
+type JobEstimator struct {
+	pg    storage.PostgresStore
+	queue *QueueEstimator
+	now   func() time.Time
+}
+
+// NewJobEstimator creates a JobEstimator. queue, which may be nil, adds
+// the expected queue wait.
+func NewJobEstimator(pg storage.PostgresStore, queue *QueueEstimator) *JobEstimator {
+	return &JobEstimator{pg: pg, queue: queue, now: time.Now}
+}
+
+// Estimate estimates the cost of analysing a file of a tenant submitted
+// now at priority. The default model is used while the tenant has none.
+func (e *JobEstimator) Estimate(ctx context.Context, tenantID uuid.UUID, priority domain.JobPriority, in EstimateInput) (*domain.JobEstimate, error) {
+	model, err := e.pg.GetJobCostModel(ctx, tenantID, in.SourceType)
+	switch {
+	case storage.IsNotFound(err):
+		m := DefaultCostModel(tenantID, in.SourceType)
+		model = &m
+	case err != nil:
+		return nil, fmt.Errorf("job estimate: %w", err)
+	}
+	if e.queue != nil {
+		queue, err := e.queue.EstimateNew(ctx, tenantID, in.SizeBytes, priority)
+		if err != nil {
+			return nil, fmt.Errorf("job estimate: %w", err)
+		}
+		in.QueueWaitMS = queue.EstimatedWaitMS
+	}
+	est := EstimateJob(*model, in, e.now())
+	return &est, nil
+}
+
+// CostModelFitter refits the cost models of every tenant from their
+// completed jobs, and records how the estimates of newly completed jobs
+// compared with their cost.
+type CostModelFitter struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+// NewCostModelFitter creates a CostModelFitter.
+func NewCostModelFitter(pg storage.PostgresStore, ch storage.ClickHouseStore) *CostModelFitter {
+	return &CostModelFitter{pg: pg, ch: ch}
+}
+
+// costModelKey identifies the cost model of a tenant's files of a source
+// type.
+type costModelKey struct {
+	tenantID   uuid.UUID
+	sourceType domain.LogSourceType
+}
+
+// Run fits the cost model of every tenant and source type with enough
+// completed jobs and returns the number of models saved. Storage per row
+// falls back to the default when ClickHouse cannot be read.
+func (f *CostModelFitter) Run(ctx context.Context, now time.Time) (int, error) {
+	samples, err := f.pg.ListJobCostSamples(ctx, costModelSampleSize)
+	if err != nil {
+		return 0, fmt.Errorf("list job cost samples: %w", err)
+	}
+
+	bytesPerRow := make(map[string]float64)
+	usage, err := f.ch.GetTenantStorage(ctx)
+	if err != nil {
+		slog.Warn("tenant storage not available to fit cost models", "error", err)
+	}
+	for _, ts := range usage {
+		if ts.Rows > 0 {
+			bytesPerRow[ts.TenantID] = float64(ts.Bytes) / float64(ts.Rows)
+		}
+	}
+
+	var keys []costModelKey
+	fit := make(map[costModelKey][]domain.JobCostSample)
+	for _, s := range samples {
+		if s.Estimate != nil && s.Accuracy == nil {
+			acc := MeasureAccuracy(*s.Estimate, s, now)
+			if err := f.pg.UpdateJobEstimateAccuracy(ctx, s.TenantID, s.JobID, &acc); err != nil {
+				slog.Warn("failed to record estimate accuracy", "job_id", s.JobID, "error", err)
+			}
+		}
+		if s.Sampled {
+			continue
+		}
+		key := costModelKey{s.TenantID, s.SourceType}
+		if _, ok := fit[key]; !ok {
+			keys = append(keys, key)
+		}
+		fit[key] = append(fit[key], s)
+	}
+
+	saved := 0
+	for _, key := range keys {
+		if len(fit[key]) < MinCostModelJobs {
+			continue
+		}
+		model := FitCostModel(key.tenantID, key.sourceType, fit[key], bytesPerRow[key.tenantID.String()], now)
+		if err := f.pg.UpsertJobCostModel(ctx, &model); err != nil {
+			return saved, fmt.Errorf("save cost model: %w", err)
+		}
+		saved++
+	}
+	return saved, nil
+}
@@ -0,0 +1,247 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var costEpoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// linearSamples returns n jobs of a tenant whose costs follow exactly:
+// 100 rows plus one per 200 bytes, the JAR 300 ms plus 1 ms per 2 KB, and
+// inserts 0.05 ms per row.
+func linearSamples(tenantID uuid.UUID, n int) []domain.JobCostSample {
+	out := make([]domain.JobCostSample, n)
+	for i := range out {
+		size := int64(i+1) << 20
+		rows := 100 + size/200
+		out[i] = domain.JobCostSample{
+			JobID:      uuid.New(),
+			TenantID:   tenantID,
+			SourceType: domain.LogSourceARServer,
+			SizeBytes:  size,
+			Rows:       rows,
+			JARMS:      300 + size/2048,
+			InsertMS:   rows / 20,
+			QueueMS:    1000,
+		}
+	}
+	return out
+}
+
+func TestFitCostModel(t *testing.T) {
+	tenantID := uuid.New()
+
+	t.Run("cold start", func(t *testing.T) {
+		model := FitCostModel(tenantID, domain.LogSourceARServer, linearSamples(tenantID, MinCostModelJobs-1), 0, costEpoch)
+		assert.Equal(t, DefaultCostModel(tenantID, domain.LogSourceARServer), model)
+
+		est := EstimateJob(model, EstimateInput{SizeBytes: 100 << 20}, costEpoch)
+		assert.Equal(t, domain.CostModelDefault, est.Model)
+		assert.Equal(t, int64(419430), est.Rows, "one row per 250 bytes")
+		assert.Equal(t, int64(50000), est.JARMS, "500 ms per MB")
+		assert.InDelta(t, 60000, est.ProcessingMS, 1000, "the queue estimator's default rate")
+	})
+
+	t.Run("fitted", func(t *testing.T) {
+		model := FitCostModel(tenantID, domain.LogSourceARServer, linearSamples(tenantID, 10), 42, costEpoch)
+		assert.Equal(t, 10, model.Jobs)
+		assert.InDelta(t, 100, model.Rows.Intercept, 1)
+		assert.InDelta(t, 1.0/200, model.Rows.Slope, 1e-6)
+		assert.InDelta(t, 300, model.JARMS.Intercept, 1)
+		assert.InDelta(t, 1.0/2048, model.JARMS.Slope, 1e-6)
+		assert.InDelta(t, 0.05, model.InsertMS.Slope, 1e-3)
+		assert.Equal(t, float64(42), model.StorageBytesPerRow)
+		assert.Equal(t, costEpoch, model.FittedAt)
+		require.NotNil(t, model.ErrorPct)
+		assert.Less(t, *model.ErrorPct, 0.1)
+
+		est := EstimateJob(model, EstimateInput{SizeBytes: 20 << 20}, costEpoch)
+		assert.Equal(t, domain.CostModelFitted, est.Model)
+		assert.Equal(t, 10, est.ModelJobs)
+		assert.InDelta(t, 100+(20<<20)/200, est.Rows, 2)
+		assert.InDelta(t, 300+(20<<20)/2048, est.JARMS, 2)
+		assert.Equal(t, est.JARMS+est.InsertMS, est.ProcessingMS)
+		assert.InDelta(t, float64(est.Rows)*42, est.StorageBytes, 42)
+	})
+
+	t.Run("same size every time", func(t *testing.T) {
+		samples := linearSamples(tenantID, MinCostModelJobs)
+		for i := range samples {
+			samples[i].SizeBytes = 1 << 20
+			samples[i].Rows = 4000
+		}
+		model := FitCostModel(tenantID, domain.LogSourceARServer, samples, 0, costEpoch)
+		assert.Equal(t, domain.LinearFit{Slope: 4000.0 / (1 << 20)}, model.Rows, "the ratio through the origin")
+	})
+
+	t.Run("nothing stored", func(t *testing.T) {
+		samples := linearSamples(tenantID, MinCostModelJobs)
+		for i := range samples {
+			samples[i].Rows = 0
+		}
+		model := FitCostModel(tenantID, domain.LogSourceJVMGC, samples, 0, costEpoch)
+		assert.Equal(t, DefaultCostModel(tenantID, domain.LogSourceJVMGC).Rows, model.Rows)
+	})
+}
+
+func TestEstimateJob(t *testing.T) {
+	model := DefaultCostModel(uuid.New(), domain.LogSourceARServer)
+
+	t.Run("sampling recommended", func(t *testing.T) {
+		est := EstimateJob(model, EstimateInput{SizeBytes: 50 << 30}, costEpoch)
+		assert.True(t, est.SamplingRecommended)
+		assert.Equal(t, 5, est.SuggestedSampleRate, "214.7M rows brought under 50M")
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		full := EstimateJob(model, EstimateInput{SizeBytes: 50 << 30}, costEpoch)
+		sampled := EstimateJob(model, EstimateInput{SizeBytes: 50 << 30, SampleRate: 10}, costEpoch)
+		assert.False(t, sampled.SamplingRecommended)
+		assert.InDelta(t, full.Rows/10, sampled.Rows, 1)
+		assert.Equal(t, full.JARMS, sampled.JARMS, "the JAR reads the whole file")
+		assert.Less(t, sampled.InsertMS, full.InsertMS)
+	})
+
+	t.Run("small file", func(t *testing.T) {
+		est := EstimateJob(model, EstimateInput{SizeBytes: 1 << 20, QueueWaitMS: 2500}, costEpoch)
+		assert.False(t, est.SamplingRecommended)
+		assert.Zero(t, est.SuggestedSampleRate)
+		assert.Equal(t, int64(2500), est.QueueWaitMS)
+		assert.Empty(t, est.QuotaExceeded)
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		quotas := domain.TenantQuotas{MaxFileBytes: 100 << 20, MaxAnalyses: 3}
+		est := EstimateJob(model, EstimateInput{SizeBytes: 200 << 20, Quotas: quotas, Analyses: 3}, costEpoch)
+		assert.Equal(t, []string{"max_file_bytes", "max_analyses"}, est.QuotaExceeded)
+
+		est = EstimateJob(model, EstimateInput{SizeBytes: 100 << 20, Quotas: quotas, Analyses: 2}, costEpoch)
+		assert.Empty(t, est.QuotaExceeded)
+	})
+}
+
+func TestMeasureAccuracy(t *testing.T) {
+	est := domain.JobEstimate{ProcessingMS: 1200, QueueWaitMS: 0, Rows: 900}
+	acc := MeasureAccuracy(est, domain.JobCostSample{JARMS: 800, InsertMS: 200, QueueMS: 500, Rows: 1000}, costEpoch)
+	assert.Equal(t, domain.EstimateAccuracy{
+		ProcessingMS:       1000,
+		QueueWaitMS:        500,
+		Rows:               1000,
+		ProcessingErrorPct: 20,
+		QueueWaitErrorPct:  100,
+		RowsErrorPct:       10,
+		RecordedAt:         costEpoch,
+	}, acc)
+}
+
+func TestJobEstimator(t *testing.T) {
+	tenantID := uuid.New()
+
+	t.Run("default model without one fitted", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJobCostModel", mock.Anything, tenantID, domain.LogSourceARServer).
+			Return(nil, fmt.Errorf("postgres: job cost model not found: ar_server"))
+		pg.On("GetJobQueueSnapshot", mock.Anything).Return(&domain.JobQueueSnapshot{
+			Running: []domain.QueuedJob{{ID: uuid.New(), SizeBytes: 10 << 20}},
+			MSPerMB: 100,
+		}, nil)
+		queue := NewQueueEstimator(pg, 1, PriorityStrict, 0)
+
+		e := NewJobEstimator(pg, queue)
+		e.now = func() time.Time { return costEpoch }
+		est, err := e.Estimate(context.Background(), tenantID, domain.JobPriorityNormal,
+			EstimateInput{SizeBytes: 1 << 20, SourceType: domain.LogSourceARServer})
+		require.NoError(t, err)
+		assert.Equal(t, domain.CostModelDefault, est.Model)
+		assert.Equal(t, costEpoch, est.EstimatedAt)
+		assert.Positive(t, est.QueueWaitMS, "behind the running job")
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("fitted model", func(t *testing.T) {
+		model := FitCostModel(tenantID, domain.LogSourceARServer, linearSamples(tenantID, 8), 0, costEpoch)
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJobCostModel", mock.Anything, tenantID, domain.LogSourceARServer).Return(&model, nil)
+
+		est, err := NewJobEstimator(pg, nil).Estimate(context.Background(), tenantID, domain.JobPriorityNormal,
+			EstimateInput{SizeBytes: 4 << 20, SourceType: domain.LogSourceARServer})
+		require.NoError(t, err)
+		assert.Equal(t, domain.CostModelFitted, est.Model)
+		assert.Zero(t, est.QueueWaitMS)
+	})
+
+	t.Run("model error", func(t *testing.T) {
+		pg := new(testutil.MockPostgresStore)
+		pg.On("GetJobCostModel", mock.Anything, tenantID, domain.LogSourceARServer).Return(nil, errors.New("connection refused"))
+		_, err := NewJobEstimator(pg, nil).Estimate(context.Background(), tenantID, domain.JobPriorityNormal,
+			EstimateInput{SizeBytes: 1 << 20, SourceType: domain.LogSourceARServer})
+		assert.ErrorContains(t, err, "connection refused")
+	})
+}
+
+func TestCostModelFitter_Run(t *testing.T) {
+	fitted, coldStart := uuid.New(), uuid.New()
+	samples := linearSamples(fitted, MinCostModelJobs)
+	estimated := &samples[0]
+	estimated.Estimate = &domain.JobEstimate{ProcessingMS: 2 * (estimated.JARMS + estimated.InsertMS), Rows: estimated.Rows}
+	samples[1].Estimate = &domain.JobEstimate{ProcessingMS: 1}
+	samples[1].Accuracy = &domain.EstimateAccuracy{} // Already recorded.
+	sampled := linearSamples(fitted, 1)[0]
+	sampled.Sampled = true
+	sampled.Rows = 1 // Would skew the fit.
+	samples = append(samples, sampled)
+	samples = append(samples, linearSamples(coldStart, MinCostModelJobs-1)...)
+
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	pg.On("ListJobCostSamples", mock.Anything, costModelSampleSize).Return(samples, nil)
+	ch.On("GetTenantStorage", mock.Anything).Return([]domain.TenantStorage{
+		{TenantID: fitted.String(), Rows: 1000, Bytes: 35000},
+	}, nil)
+	pg.On("UpdateJobEstimateAccuracy", mock.Anything, fitted, estimated.JobID, mock.MatchedBy(func(acc *domain.EstimateAccuracy) bool {
+		return acc.ProcessingErrorPct == 100 && acc.RowsErrorPct == 0 && acc.RecordedAt.Equal(costEpoch)
+	})).Return(nil).Once()
+	var saved *domain.JobCostModel
+	pg.On("UpsertJobCostModel", mock.Anything, mock.AnythingOfType("*domain.JobCostModel")).
+		Run(func(args mock.Arguments) { saved = args.Get(1).(*domain.JobCostModel) }).
+		Return(nil).Once()
+
+	n, err := NewCostModelFitter(pg, ch).Run(context.Background(), costEpoch)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "the cold-start tenant keeps the default model")
+	pg.AssertExpectations(t)
+
+	require.NotNil(t, saved)
+	assert.Equal(t, fitted, saved.TenantID)
+	assert.Equal(t, MinCostModelJobs, saved.Jobs, "sampled jobs are not fitted on")
+	assert.InDelta(t, 1.0/200, saved.Rows.Slope, 1e-6)
+	assert.Equal(t, float64(35), saved.StorageBytesPerRow)
+}
+
+func TestCostModelFitter_Run_StorageUnavailable(t *testing.T) {
+	tenantID := uuid.New()
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	pg.On("ListJobCostSamples", mock.Anything, costModelSampleSize).Return(linearSamples(tenantID, MinCostModelJobs), nil)
+	ch.On("GetTenantStorage", mock.Anything).Return(nil, errors.New("clickhouse unavailable"))
+	pg.On("UpsertJobCostModel", mock.Anything, mock.MatchedBy(func(m *domain.JobCostModel) bool {
+		return m.StorageBytesPerRow == defaultStorageBytesPerRow
+	})).Return(nil).Once()
+
+	n, err := NewCostModelFitter(pg, ch).Run(context.Background(), costEpoch)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	pg.AssertExpectations(t)
+}
@@ -0,0 +1,323 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/compare"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/notify"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// digestTopForms is the number of slow forms and regressions listed.
+const digestTopForms = 5
+
+// Digest summarises a tenant's analyses over one local day. The Has* flags
+// are false when a section's data could not be collected, so the rendered
+// digest can say so instead of showing an empty table.
+type Digest struct {
+	TenantName  string
+	Location    *time.Location
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Analyses    []DigestAnalysis
+
+	HasExceptionDiff bool
+	NewExceptions    []DigestException
+
+	HasSlowForms bool
+	SlowForms    []DigestFormLatency
+
+	HasRegressions bool
+	Regressions    []DigestFormLatency
+}
+
+// DigestAnalysis is one completed analysis in the digest period.
+type DigestAnalysis struct {
+	JobID          uuid.UUID
+	Filename       string
+	CompletedAt    time.Time
+	HealthScore    *domain.HealthScore
+	PreviousScore  *int // Score of the capture completed before this one
+	ViolationCount *int
+}
+
+// HealthDelta returns the score change against the previous capture.
+func (a DigestAnalysis) HealthDelta() (int, bool) {
+	if a.HealthScore == nil || a.PreviousScore == nil {
+		return 0, false
+	}
+	return a.HealthScore.Score - *a.PreviousScore, true
+}
+
+// DigestException is an error code seen in the latest capture but not in the
+// one before it.
+type DigestException struct {
+	ErrorCode string
+	Message   string
+	Count     int64
+}
+
+// DigestFormLatency is the API latency of one form in the latest capture,
+// with the previous capture's figure when the form appeared there.
+type DigestFormLatency struct {
+	Form          string
+	Count         int64
+	AvgMS         float64
+	PreviousAvgMS *float64
+}
+
+// DeltaMS returns the change in average latency against the previous capture.
+func (f DigestFormLatency) DeltaMS() float64 {
+	if f.PreviousAvgMS == nil {
+		return 0
+	}
+	return f.AvgMS - *f.PreviousAvgMS
+}
+
+// DigestComposer collects the data of a tenant digest from existing stores.
+type DigestComposer struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+// NewDigestComposer creates a DigestComposer.
+func NewDigestComposer(pg storage.PostgresStore, ch storage.ClickHouseStore) *DigestComposer {
+	return &DigestComposer{pg: pg, ch: ch}
+}
+
+// Compose builds the digest for analyses completed in [start, end). It
+// returns nil when the tenant completed no analyses in the period. Failures
+// of individual sections are logged and leave that section unavailable.
+func (c *DigestComposer) Compose(ctx context.Context, tenantID uuid.UUID, start, end time.Time) (*Digest, error) {
+	ctx = storage.WithTenant(ctx, tenantID.String())
+	jobs, err := c.pg.ListJobs(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+
+	// Completed jobs in completion order; the period's jobs are a suffix
+	// of the ones completed before end.
+	var completed []domain.AnalysisJob
+	for _, j := range jobs {
+		if j.Status == domain.JobStatusComplete && j.CompletedAt != nil && j.CompletedAt.Before(end) {
+			completed = append(completed, j)
+		}
+	}
+	sort.Slice(completed, func(a, b int) bool { return completed[a].CompletedAt.Before(*completed[b].CompletedAt) })
+
+	first := sort.Search(len(completed), func(i int) bool { return !completed[i].CompletedAt.Before(start) })
+	if first == len(completed) {
+		return nil, nil
+	}
+
+	d := &Digest{
+		Location:    start.Location(),
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+	if tenant, err := c.pg.GetTenant(ctx, tenantID); err == nil {
+		d.TenantName = tenant.Name
+	}
+
+	logger := slog.With("tenant_id", tenantID.String())
+	profile, err := storage.LoadHealthProfile(ctx, c.pg, tenantID)
+	if err != nil {
+		logger.Warn("digest: health profile unavailable, using default", "error", err)
+		profile = nil
+	}
+	health := make(map[uuid.UUID]*domain.HealthScore)
+	healthOf := func(j domain.AnalysisJob) *domain.HealthScore {
+		if h, ok := health[j.ID]; ok {
+			return h
+		}
+		h, err := c.ch.ComputeHealthScore(ctx, tenantID.String(), j.ID.String(), profile, j.Restarts)
+		if err != nil {
+			logger.Warn("digest: health score unavailable", "job_id", j.ID.String(), "error", err)
+			h = nil
+		}
+		health[j.ID] = h
+		return h
+	}
+
+	for i := first; i < len(completed); i++ {
+		j := completed[i]
+		a := DigestAnalysis{
+			JobID:          j.ID,
+			CompletedAt:    *j.CompletedAt,
+			HealthScore:    healthOf(j),
+			ViolationCount: j.ViolationCount,
+		}
+		if f, err := c.pg.GetLogFile(ctx, tenantID, j.FileID); err == nil {
+			a.Filename = f.Filename
+		}
+		if i > 0 {
+			if prev := healthOf(completed[i-1]); prev != nil {
+				score := prev.Score
+				a.PreviousScore = &score
+			}
+		}
+		d.Analyses = append(d.Analyses, a)
+	}
+
+	// Exception and latency comparisons use the latest capture of the day
+	// against the capture completed immediately before it.
+	latest := completed[len(completed)-1]
+	var baseline *domain.AnalysisJob
+	if len(completed) > 1 {
+		baseline = &completed[len(completed)-2]
+	}
+	c.composeExceptions(ctx, d, tenantID, latest, baseline)
+	c.composeFormLatency(ctx, d, tenantID, latest, baseline)
+	return d, nil
+}
+
+func (c *DigestComposer) composeExceptions(ctx context.Context, d *Digest, tenantID uuid.UUID, latest domain.AnalysisJob, baseline *domain.AnalysisJob) {
+	if baseline == nil {
+		return
+	}
+	logger := slog.With("tenant_id", tenantID.String())
+
+	cur, err := c.ch.GetExceptions(ctx, tenantID.String(), latest.ID.String())
+	if err != nil {
+		logger.Warn("digest: exceptions unavailable", "job_id", latest.ID.String(), "error", err)
+		return
+	}
+	prev, err := c.ch.GetExceptions(ctx, tenantID.String(), baseline.ID.String())
+	if err != nil {
+		logger.Warn("digest: exceptions unavailable", "job_id", baseline.ID.String(), "error", err)
+		return
+	}
+
+	d.HasExceptionDiff = true
+	// Appeared codes lead the comparison, most frequent first.
+	for _, ec := range compare.DiffExceptions(prev.Exceptions, cur.Exceptions, 0, 0) {
+		if ec.Change != domain.ExceptionAppeared {
+			break
+		}
+		d.NewExceptions = append(d.NewExceptions, DigestException{ErrorCode: ec.ErrorCode, Message: ec.Message, Count: ec.CandidateCount})
+	}
+}
+
+func (c *DigestComposer) composeFormLatency(ctx context.Context, d *Digest, tenantID uuid.UUID, latest domain.AnalysisJob, baseline *domain.AnalysisJob) {
+	logger := slog.With("tenant_id", tenantID.String())
+
+	cur, err := c.ch.GetAggregates(ctx, tenantID.String(), latest.ID.String())
+	if err != nil {
+		logger.Warn("digest: aggregates unavailable", "job_id", latest.ID.String(), "error", err)
+		return
+	}
+	if cur.API == nil {
+		d.HasSlowForms = true
+		return
+	}
+
+	var base *domain.AggregateSection
+	hasBaseline := false
+	if baseline != nil {
+		prev, err := c.ch.GetAggregates(ctx, tenantID.String(), baseline.ID.String())
+		if err != nil {
+			logger.Warn("digest: aggregates unavailable", "job_id", baseline.ID.String(), "error", err)
+		} else {
+			base, hasBaseline = prev.API, true
+		}
+	}
+
+	diff := compare.DiffForms(base, cur.API)
+	forms := make([]DigestFormLatency, 0, len(diff))
+	for _, f := range diff {
+		forms = append(forms, digestForm(f))
+	}
+
+	sort.SliceStable(forms, func(a, b int) bool { return forms[a].AvgMS > forms[b].AvgMS })
+	d.HasSlowForms = true
+	d.SlowForms = forms[:min(len(forms), digestTopForms)]
+
+	if !hasBaseline {
+		return
+	}
+	d.HasRegressions = true
+	for _, f := range compare.Regressions(diff, digestTopForms) {
+		d.Regressions = append(d.Regressions, digestForm(f))
+	}
+}
+
+func digestForm(f domain.FormComparison) DigestFormLatency {
+	return DigestFormLatency{Form: f.Form, Count: f.CandidateCount, AvgMS: f.CandidateAvgMS, PreviousAvgMS: f.BaselineAvgMS}
+}
+
+// DigestScheduler sends each subscribed tenant its digest for the previous
+// local day once the subscription's send hour has passed.
+type DigestScheduler struct {
+	pg       storage.PostgresStore
+	composer *DigestComposer
+	notifier notify.Notifier
+}
+
+// NewDigestScheduler creates a DigestScheduler.
+func NewDigestScheduler(pg storage.PostgresStore, ch storage.ClickHouseStore, notifier notify.Notifier) *DigestScheduler {
+	return &DigestScheduler{pg: pg, composer: NewDigestComposer(pg, ch), notifier: notifier}
+}
+
+// DigestPeriod returns the local day a digest sent at now covers, and whether
+// the subscription's send hour has been reached.
+func DigestPeriod(sub domain.DigestSubscription, loc *time.Location, now time.Time) (start, end time.Time, due bool) {
+	local := now.In(loc)
+	end = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	start = end.AddDate(0, 0, -1)
+	return start, end, local.Hour() >= sub.SendHour
+}
+
+// RunDue produces every digest due at now and returns how many were sent.
+// Tenants without analyses in the period are marked done without a message.
+// A failed send is retried on the next run.
+func (s *DigestScheduler) RunDue(ctx context.Context, now time.Time) (int, error) {
+	subs, err := s.pg.ListDigestSubscriptions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list digest subscriptions: %w", err)
+	}
+
+	sent := 0
+	for _, sub := range subs {
+		logger := slog.With("tenant_id", sub.TenantID.String())
+
+		loc, err := time.LoadLocation(sub.Timezone)
+		if err != nil {
+			logger.Warn("digest: invalid timezone", "timezone", sub.Timezone, "error", err)
+			continue
+		}
+		start, end, due := DigestPeriod(sub, loc, now)
+		if !due || (sub.LastPeriodEnd != nil && !sub.LastPeriodEnd.Before(end)) {
+			continue
+		}
+
+		digest, err := s.composer.Compose(ctx, sub.TenantID, start, end)
+		if err != nil {
+			logger.Warn("digest: compose failed", "error", err)
+			continue
+		}
+		if digest != nil {
+			subject, html, err := RenderDigest(digest)
+			if err != nil {
+				logger.Warn("digest: render failed", "error", err)
+				continue
+			}
+			if err := s.notifier.Send(ctx, notify.Message{To: sub.Recipients, Subject: subject, HTML: html}); err != nil {
+				logger.Warn("digest: send failed", "error", err)
+				continue
+			}
+			sent++
+		}
+
+		if err := s.pg.MarkDigestSent(ctx, sub.TenantID, end); err != nil {
+			logger.Warn("digest: failed to record send", "error", err)
+		}
+	}
+	return sent, nil
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"fmtDate": func(t time.Time) string {
+		return t.Format("Mon Jan 2, 2006")
+	},
+	"fmtClock": func(t time.Time, loc *time.Location) string {
+		return t.In(loc).Format("15:04 MST")
+	},
+	"fmtMS": func(f float64) string {
+		if f < 1000 {
+			return fmt.Sprintf("%.1f ms", f)
+		}
+		return fmt.Sprintf("%.2f s", f/1000)
+	},
+	"fmtDeltaMS": func(f float64) string {
+		if f < 1000 && f > -1000 {
+			return fmt.Sprintf("%+.1f ms", f)
+		}
+		return fmt.Sprintf("%+.2f s", f/1000)
+	},
+	"fmtDelta": func(a DigestAnalysis) string {
+		d, ok := a.HealthDelta()
+		if !ok {
+			return "-"
+		}
+		return fmt.Sprintf("%+d", d)
+	},
+}).Parse(digestHTML))
+
+// RenderDigest renders d as an email subject and HTML body.
+func RenderDigest(d *Digest) (subject, html string, err error) {
+	var b bytes.Buffer
+	if err := digestTemplate.Execute(&b, d); err != nil {
+		return "", "", fmt.Errorf("render digest: %w", err)
+	}
+
+	noun := "analyses"
+	if len(d.Analyses) == 1 {
+		noun = "analysis"
+	}
+	subject = fmt.Sprintf("RemedyIQ daily digest for %s: %d %s", d.PeriodStart.Format("Jan 2"), len(d.Analyses), noun)
+	return subject, b.String(), nil
+}
+
+const digestHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>RemedyIQ daily digest</title>
+</head>
+<body style="font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; color: #1f2937; max-width: 720px;">
+<h1 style="font-size: 20px;">Daily digest{{with .TenantName}} for {{.}}{{end}}</h1>
+<p style="color: #6b7280;">{{fmtDate .PeriodStart}} ({{.Location}})</p>
+
+<h2 style="font-size: 16px;">Analyses completed</h2>
+<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+<tr style="text-align: left; border-bottom: 1px solid #e5e7eb;"><th>File</th><th>Completed</th><th>Health</th><th>Change</th><th>Violations</th></tr>
+{{- range .Analyses}}
+<tr style="border-bottom: 1px solid #f3f4f6;">
+<td>{{if .Filename}}{{.Filename}}{{else}}{{.JobID}}{{end}}</td>
+<td>{{fmtClock .CompletedAt $.Location}}</td>
+<td>{{with .HealthScore}}{{.Score}} ({{.Status}}){{else}}-{{end}}</td>
+<td>{{fmtDelta .}}</td>
+<td>{{with .ViolationCount}}{{.}}{{else}}-{{end}}</td>
+</tr>
+{{- end}}
+</table>
+
+<h2 style="font-size: 16px;">New exception codes</h2>
+{{- if not .HasExceptionDiff}}
+<p style="color: #6b7280;">Not available: no earlier capture to compare against, or exception data could not be loaded.</p>
+{{- else if not .NewExceptions}}
+<p>No new exception codes since the previous capture.</p>
+{{- else}}
+<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+<tr style="text-align: left; border-bottom: 1px solid #e5e7eb;"><th>Code</th><th>Message</th><th>Count</th></tr>
+{{- range .NewExceptions}}
+<tr style="border-bottom: 1px solid #f3f4f6;"><td>{{.ErrorCode}}</td><td>{{.Message}}</td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<h2 style="font-size: 16px;">Worst regressions</h2>
+{{- if not .HasRegressions}}
+<p style="color: #6b7280;">Not available: no earlier capture to compare against, or latency data could not be loaded.</p>
+{{- else if not .Regressions}}
+<p>No form got slower than in the previous capture.</p>
+{{- else}}
+<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+<tr style="text-align: left; border-bottom: 1px solid #e5e7eb;"><th>Form</th><th>Avg</th><th>Previous</th><th>Change</th></tr>
+{{- range .Regressions}}
+<tr style="border-bottom: 1px solid #f3f4f6;"><td>{{.Form}}</td><td>{{fmtMS .AvgMS}}</td><td>{{with .PreviousAvgMS}}{{fmtMS .}}{{end}}</td><td>{{fmtDeltaMS .DeltaMS}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<h2 style="font-size: 16px;">Slowest forms</h2>
+{{- if not .HasSlowForms}}
+<p style="color: #6b7280;">Not available: latency data could not be loaded.</p>
+{{- else if not .SlowForms}}
+<p>No API calls were recorded.</p>
+{{- else}}
+<table cellpadding="6" style="border-collapse: collapse; width: 100%;">
+<tr style="text-align: left; border-bottom: 1px solid #e5e7eb;"><th>Form</th><th>Calls</th><th>Avg</th></tr>
+{{- range .SlowForms}}
+<tr style="border-bottom: 1px solid #f3f4f6;"><td>{{.Form}}</td><td>{{.Count}}</td><td>{{fmtMS .AvgMS}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<p style="color: #9ca3af; font-size: 12px;">Figures for exceptions and latency compare the latest capture of the day with the capture completed before it.</p>
+</body>
+</html>
+`
@@ -0,0 +1,346 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/notify"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files in testdata")
+
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("..", "..", "testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got)
+}
+
+func intPtr(i int) *int              { return &i }
+func floatPtr(f float64) *float64    { return &f }
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestRenderDigest_Golden(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	start := time.Date(2026, 3, 9, 0, 0, 0, 0, berlin)
+
+	full := &Digest{
+		TenantName:  "Acme <Ops>",
+		Location:    berlin,
+		PeriodStart: start,
+		PeriodEnd:   start.AddDate(0, 0, 1),
+		Analyses: []DigestAnalysis{
+			{
+				JobID:          uuid.MustParse("00000000-0000-0000-0000-00000000000a"),
+				Filename:       "arserver_0200.log",
+				CompletedAt:    time.Date(2026, 3, 9, 1, 15, 0, 0, time.UTC),
+				HealthScore:    &domain.HealthScore{Score: 82, Status: "green"},
+				PreviousScore:  intPtr(90),
+				ViolationCount: intPtr(0),
+			},
+			{
+				JobID:          uuid.MustParse("00000000-0000-0000-0000-00000000000b"),
+				CompletedAt:    time.Date(2026, 3, 9, 13, 5, 0, 0, time.UTC),
+				HealthScore:    &domain.HealthScore{Score: 64, Status: "yellow"},
+				PreviousScore:  intPtr(82),
+				ViolationCount: intPtr(3),
+			},
+		},
+		HasExceptionDiff: true,
+		NewExceptions: []DigestException{
+			{ErrorCode: "ARERR 9352", Message: "Cannot find entry", Count: 17},
+		},
+		HasSlowForms: true,
+		SlowForms: []DigestFormLatency{
+			{Form: "HPD:Help Desk", Count: 120, AvgMS: 2450, PreviousAvgMS: floatPtr(1200)},
+			{Form: "CHG:Infrastructure Change", Count: 40, AvgMS: 310.5},
+		},
+		HasRegressions: true,
+		Regressions: []DigestFormLatency{
+			{Form: "HPD:Help Desk", Count: 120, AvgMS: 2450, PreviousAvgMS: floatPtr(1200)},
+		},
+	}
+
+	subject, html, err := RenderDigest(full)
+	require.NoError(t, err)
+	assert.Equal(t, "RemedyIQ daily digest for Mar 9: 2 analyses", subject)
+	assertGolden(t, "digest_full.golden.html", html)
+
+	// Sections whose data could not be collected degrade to a notice.
+	degraded := &Digest{
+		Location:    time.UTC,
+		PeriodStart: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+		Analyses: []DigestAnalysis{
+			{
+				JobID:       uuid.MustParse("00000000-0000-0000-0000-00000000000c"),
+				CompletedAt: time.Date(2026, 3, 9, 6, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	subject, html, err = RenderDigest(degraded)
+	require.NoError(t, err)
+	assert.Equal(t, "RemedyIQ daily digest for Mar 9: 1 analysis", subject)
+	assertGolden(t, "digest_degraded.golden.html", html)
+}
+
+// digestFixture wires mocks for a tenant with one capture on the previous
+// day and one in the digest period.
+type digestFixture struct {
+	pg       *testutil.MockPostgresStore
+	ch       *testutil.MockClickHouseStore
+	notifier *testutil.MockNotifier
+	tenantID uuid.UUID
+	prev     domain.AnalysisJob
+	latest   domain.AnalysisJob
+}
+
+func newDigestFixture(t *testing.T) *digestFixture {
+	t.Helper()
+	f := &digestFixture{
+		pg:       new(testutil.MockPostgresStore),
+		ch:       new(testutil.MockClickHouseStore),
+		notifier: new(testutil.MockNotifier),
+		tenantID: uuid.New(),
+	}
+	f.prev = domain.AnalysisJob{
+		ID: uuid.New(), TenantID: f.tenantID, FileID: uuid.New(), Status: domain.JobStatusComplete,
+		CompletedAt: timePtr(time.Date(2026, 3, 8, 22, 0, 0, 0, time.UTC)),
+	}
+	f.latest = domain.AnalysisJob{
+		ID: uuid.New(), TenantID: f.tenantID, FileID: uuid.New(), Status: domain.JobStatusComplete,
+		CompletedAt:    timePtr(time.Date(2026, 3, 9, 2, 0, 0, 0, time.UTC)),
+		ViolationCount: intPtr(1),
+	}
+	return f
+}
+
+func (f *digestFixture) expectCompose() {
+	tid := f.tenantID.String()
+	running := domain.AnalysisJob{ID: uuid.New(), TenantID: f.tenantID, Status: domain.JobStatusParsing}
+	f.pg.On("ListJobs", mock.Anything, f.tenantID).Return([]domain.AnalysisJob{f.latest, running, f.prev}, nil)
+	f.pg.On("GetTenant", mock.Anything, f.tenantID).Return(&domain.Tenant{ID: f.tenantID, Name: "Acme"}, nil)
+	f.pg.On("GetLogFile", mock.Anything, f.tenantID, f.latest.FileID).Return(&domain.LogFile{Filename: "arserver.log"}, nil)
+
+	profile := storage.DefaultHealthProfile()
+	profile.TenantID, profile.Version = f.tenantID, 3
+	f.pg.On("GetHealthProfile", mock.Anything, f.tenantID).Return(profile, nil).Once()
+	f.ch.On("ComputeHealthScore", mock.Anything, tid, f.latest.ID.String(), profile, mock.Anything).Return(&domain.HealthScore{Score: 70, Status: "yellow", ProfileVersion: 3}, nil).Once()
+	f.ch.On("ComputeHealthScore", mock.Anything, tid, f.prev.ID.String(), profile, mock.Anything).Return(&domain.HealthScore{Score: 88, Status: "green", ProfileVersion: 3}, nil).Once()
+
+	f.ch.On("GetExceptions", mock.Anything, tid, f.latest.ID.String()).Return(&domain.ExceptionsResponse{Exceptions: []domain.ExceptionEntry{
+		{ErrorCode: "ARERR 302", Count: 4},
+		{ErrorCode: "ARERR 9352", Count: 9},
+	}}, nil)
+	f.ch.On("GetExceptions", mock.Anything, tid, f.prev.ID.String()).Return(&domain.ExceptionsResponse{Exceptions: []domain.ExceptionEntry{
+		{ErrorCode: "ARERR 302", Count: 2},
+	}}, nil)
+
+	f.ch.On("GetAggregates", mock.Anything, tid, f.latest.ID.String()).Return(&domain.AggregatesResponse{API: &domain.AggregateSection{Groups: []domain.AggregateGroup{
+		{Name: "HPD:Help Desk", Count: 10, AvgMS: 900},
+		{Name: "CHG:Change", Count: 5, AvgMS: 300},
+		{Name: "New:Form", Count: 1, AvgMS: 5000},
+	}}}, nil)
+	f.ch.On("GetAggregates", mock.Anything, tid, f.prev.ID.String()).Return(&domain.AggregatesResponse{API: &domain.AggregateSection{Groups: []domain.AggregateGroup{
+		{Name: "HPD:Help Desk", Count: 12, AvgMS: 400},
+		{Name: "CHG:Change", Count: 6, AvgMS: 350},
+	}}}, nil)
+}
+
+func TestDigestComposer_Compose(t *testing.T) {
+	f := newDigestFixture(t)
+	f.expectCompose()
+
+	start := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	d, err := NewDigestComposer(f.pg, f.ch).Compose(context.Background(), f.tenantID, start, start.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.NotNil(t, d)
+
+	assert.Equal(t, "Acme", d.TenantName)
+	require.Len(t, d.Analyses, 1)
+	a := d.Analyses[0]
+	assert.Equal(t, "arserver.log", a.Filename)
+	delta, ok := a.HealthDelta()
+	require.True(t, ok)
+	assert.Equal(t, -18, delta)
+
+	require.True(t, d.HasExceptionDiff)
+	require.Len(t, d.NewExceptions, 1)
+	assert.Equal(t, "ARERR 9352", d.NewExceptions[0].ErrorCode)
+
+	require.True(t, d.HasSlowForms)
+	assert.Equal(t, "New:Form", d.SlowForms[0].Form)
+	require.True(t, d.HasRegressions)
+	require.Len(t, d.Regressions, 1)
+	assert.Equal(t, "HPD:Help Desk", d.Regressions[0].Form)
+	assert.Equal(t, 500.0, d.Regressions[0].DeltaMS())
+
+	f.pg.AssertExpectations(t)
+	f.ch.AssertExpectations(t)
+}
+
+func TestDigestComposer_NoAnalysesInPeriod(t *testing.T) {
+	f := newDigestFixture(t)
+	f.pg.On("ListJobs", mock.Anything, f.tenantID).Return([]domain.AnalysisJob{f.prev}, nil)
+
+	start := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	d, err := NewDigestComposer(f.pg, f.ch).Compose(context.Background(), f.tenantID, start, start.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Nil(t, d)
+	f.ch.AssertNotCalled(t, "ComputeHealthScore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDigestComposer_SectionFailuresDegrade(t *testing.T) {
+	f := newDigestFixture(t)
+	tid := f.tenantID.String()
+	f.pg.On("ListJobs", mock.Anything, f.tenantID).Return([]domain.AnalysisJob{f.latest}, nil)
+	f.pg.On("GetTenant", mock.Anything, f.tenantID).Return(nil, errors.New("db down"))
+	f.pg.On("GetLogFile", mock.Anything, f.tenantID, f.latest.FileID).Return(nil, errors.New("db down"))
+	f.pg.On("GetHealthProfile", mock.Anything, f.tenantID).Return(nil, errors.New("db down"))
+	f.ch.On("ComputeHealthScore", mock.Anything, tid, f.latest.ID.String(), (*domain.HealthProfile)(nil), mock.Anything).Return(nil, errors.New("timeout"))
+	f.ch.On("GetAggregates", mock.Anything, tid, f.latest.ID.String()).Return(nil, errors.New("timeout"))
+
+	start := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	d, err := NewDigestComposer(f.pg, f.ch).Compose(context.Background(), f.tenantID, start, start.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	require.Len(t, d.Analyses, 1)
+	assert.Nil(t, d.Analyses[0].HealthScore)
+	assert.False(t, d.HasExceptionDiff)
+	assert.False(t, d.HasSlowForms)
+	assert.False(t, d.HasRegressions)
+
+	_, _, err = RenderDigest(d)
+	assert.NoError(t, err)
+}
+
+func TestDigestPeriod(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	sub := domain.DigestSubscription{SendHour: 7}
+
+	// 06:59 EDT: the previous day is known but not yet due.
+	start, end, due := DigestPeriod(sub, ny, time.Date(2026, 3, 10, 10, 59, 0, 0, time.UTC))
+	assert.False(t, due)
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, ny), start)
+	assert.Equal(t, time.Date(2026, 3, 10, 0, 0, 0, 0, ny), end)
+
+	_, _, due = DigestPeriod(sub, ny, time.Date(2026, 3, 10, 11, 0, 0, 0, time.UTC))
+	assert.True(t, due)
+
+	// The DST switch on Mar 8 makes the covered day 23 hours long.
+	start, end, _ = DigestPeriod(sub, ny, time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, 23*time.Hour, end.Sub(start))
+}
+
+func TestDigestScheduler_RunDue(t *testing.T) {
+	periodEnd := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		lastSent *time.Time
+		setup    func(f *digestFixture)
+		wantSent int
+	}{
+		{
+			name:     "before send hour does nothing",
+			now:      time.Date(2026, 3, 10, 6, 59, 0, 0, time.UTC),
+			setup:    func(f *digestFixture) {},
+			wantSent: 0,
+		},
+		{
+			name:     "already sent for the period",
+			now:      time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+			lastSent: &periodEnd,
+			setup:    func(f *digestFixture) {},
+			wantSent: 0,
+		},
+		{
+			name: "due digest is sent and recorded",
+			now:  time.Date(2026, 3, 10, 7, 5, 0, 0, time.UTC),
+			setup: func(f *digestFixture) {
+				f.latest.CompletedAt = timePtr(time.Date(2026, 3, 9, 2, 0, 0, 0, time.UTC))
+				f.expectCompose()
+				f.notifier.On("Send", mock.Anything, mock.MatchedBy(func(m notify.Message) bool {
+					return len(m.To) == 1 && m.To[0] == "ops@example.com" &&
+						m.Subject == "RemedyIQ daily digest for Mar 9: 1 analysis"
+				})).Return(nil).Once()
+				f.pg.On("MarkDigestSent", mock.Anything, f.tenantID, periodEnd).Return(nil).Once()
+			},
+			wantSent: 1,
+		},
+		{
+			name: "no analyses skips send but records the period",
+			now:  time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+			setup: func(f *digestFixture) {
+				f.pg.On("ListJobs", mock.Anything, f.tenantID).Return([]domain.AnalysisJob{}, nil)
+				f.pg.On("MarkDigestSent", mock.Anything, f.tenantID, periodEnd).Return(nil).Once()
+			},
+			wantSent: 0,
+		},
+		{
+			name: "send failure is retried on the next run",
+			now:  time.Date(2026, 3, 10, 7, 5, 0, 0, time.UTC),
+			setup: func(f *digestFixture) {
+				f.expectCompose()
+				f.notifier.On("Send", mock.Anything, mock.Anything).Return(errors.New("smtp down")).Once()
+			},
+			wantSent: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newDigestFixture(t)
+			f.pg.On("ListDigestSubscriptions", mock.Anything).Return([]domain.DigestSubscription{{
+				TenantID:      f.tenantID,
+				Enabled:       true,
+				Recipients:    []string{"ops@example.com"},
+				SendHour:      7,
+				Timezone:      "UTC",
+				LastPeriodEnd: tc.lastSent,
+			}}, nil)
+			tc.setup(f)
+
+			sent, err := NewDigestScheduler(f.pg, f.ch, f.notifier).RunDue(context.Background(), tc.now)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSent, sent)
+
+			f.pg.AssertExpectations(t)
+			f.notifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDigestScheduler_InvalidTimezoneSkipped(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListDigestSubscriptions", mock.Anything).Return([]domain.DigestSubscription{{
+		TenantID: uuid.New(), Enabled: true, Recipients: []string{"ops@example.com"}, Timezone: "Mars/Olympus",
+	}}, nil)
+
+	sent, err := NewDigestScheduler(pg, new(testutil.MockClickHouseStore), new(testutil.MockNotifier)).
+		RunDue(context.Background(), time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	pg.AssertNotCalled(t, "MarkDigestSent", mock.Anything, mock.Anything, mock.Anything)
+}
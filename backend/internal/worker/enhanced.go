@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"slices"
 	"sort"
 	"time"
 
@@ -95,6 +96,7 @@ func computeAggregateSection(entries []domain.TopNEntry, groupByField string) *d
 
 		acc.count++
 		acc.totalMS += int64(e.DurationMS)
+		acc.durations = append(acc.durations, int64(e.DurationMS))
 		if int64(e.DurationMS) < acc.minMS {
 			acc.minMS = int64(e.DurationMS)
 		}
@@ -124,7 +126,7 @@ func computeAggregateSection(entries []domain.TopNEntry, groupByField string) *d
 			errorRate = float64(acc.errorCount) / float64(acc.count) * 100
 		}
 
-		resultGroups = append(resultGroups, domain.AggregateGroup{
+		group := domain.AggregateGroup{
 			Name:         name,
 			Count:        acc.count,
 			TotalMS:      acc.totalMS,
@@ -134,10 +136,13 @@ func computeAggregateSection(entries []domain.TopNEntry, groupByField string) *d
 			ErrorCount:   acc.errorCount,
 			ErrorRate:    errorRate,
 			UniqueTraces: len(acc.traces),
-		})
+		}
+		setTimedDurations(&group, acc.durations)
+		resultGroups = append(resultGroups, group)
 
 		grandTotal.count += acc.count
 		grandTotal.totalMS += acc.totalMS
+		grandTotal.durations = append(grandTotal.durations, acc.durations...)
 		grandTotal.errorCount += acc.errorCount
 		if grandTotal.minMS < 0 || acc.minMS < grandTotal.minMS {
 			grandTotal.minMS = acc.minMS
@@ -170,6 +175,7 @@ func computeAggregateSection(entries []domain.TopNEntry, groupByField string) *d
 			ErrorRate:    errorRate,
 			UniqueTraces: len(grandTotal.traces),
 		}
+		setTimedDurations(section.GrandTotal, grandTotal.durations)
 	}
 
 	return section
@@ -182,6 +188,42 @@ type aggregateAccumulator struct {
 	maxMS      int64
 	errorCount int64
 	traces     map[string]bool
+	durations  []int64
+}
+
+// setTimedDurations sets the 95th percentile duration of g from the
+// durations of its calls, and the count, average and 95th percentile of
+// those timed above zero.
+func setTimedDurations(g *domain.AggregateGroup, durations []int64) {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	g.P95MS = percentileMS(sorted, 0.95)
+
+	timed := sorted[sort.Search(len(sorted), func(i int) bool { return sorted[i] > 0 }):]
+	g.TimedCount = int64(len(timed))
+	if len(timed) == 0 {
+		return
+	}
+	var totalMS int64
+	for _, d := range timed {
+		totalMS += d
+	}
+	g.AvgTimedMS = float64(totalMS) / float64(len(timed))
+	g.P95TimedMS = percentileMS(timed, 0.95)
+}
+
+// percentileMS returns the q quantile of sorted, interpolating linearly
+// between the closest ranks.
+func percentileMS(sorted []int64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo+1 >= len(sorted) {
+		return float64(sorted[lo])
+	}
+	return float64(sorted[lo]) + (pos-float64(lo))*float64(sorted[lo+1]-sorted[lo])
 }
 
 func getGroupKey(e domain.TopNEntry, field string) string {
@@ -216,8 +258,8 @@ func computeExceptions(dashboard *domain.DashboardData) *domain.ExceptionsRespon
 			ErrorCode: code,
 			Message:   code,
 			Count:     int64(count),
-			FirstSeen: now,
-			LastSeen:  now,
+			FirstSeen: domain.NewTimestamp(now),
+			LastSeen:  domain.NewTimestamp(now),
 			LogType:   domain.LogTypeAPI,
 		})
 	}
@@ -399,7 +441,7 @@ func computeGaps(dashboard *domain.DashboardData) *domain.GapsResponse {
 	}
 
 	sort.Slice(dashboard.TimeSeries, func(i, j int) bool {
-		return dashboard.TimeSeries[i].Timestamp.Before(dashboard.TimeSeries[j].Timestamp)
+		return dashboard.TimeSeries[i].Timestamp.Before(dashboard.TimeSeries[j].Timestamp.Time)
 	})
 
 	queueStats := make(map[string]*queueAccumulator)
@@ -483,22 +525,22 @@ func generateTimeSeries(dashboard *domain.DashboardData) []domain.TimeSeriesPoin
 
 	for _, e := range dashboard.TopAPICalls {
 		if !e.Timestamp.IsZero() {
-			entries = append(entries, entryWithType{ts: e.Timestamp, logType: "api", duration: e.DurationMS, success: e.Success})
+			entries = append(entries, entryWithType{ts: e.Timestamp.Time, logType: "api", duration: e.DurationMS, success: e.Success})
 		}
 	}
 	for _, e := range dashboard.TopSQL {
 		if !e.Timestamp.IsZero() {
-			entries = append(entries, entryWithType{ts: e.Timestamp, logType: "sql", duration: e.DurationMS, success: e.Success})
+			entries = append(entries, entryWithType{ts: e.Timestamp.Time, logType: "sql", duration: e.DurationMS, success: e.Success})
 		}
 	}
 	for _, e := range dashboard.TopFilters {
 		if !e.Timestamp.IsZero() {
-			entries = append(entries, entryWithType{ts: e.Timestamp, logType: "filter", duration: e.DurationMS, success: e.Success})
+			entries = append(entries, entryWithType{ts: e.Timestamp.Time, logType: "filter", duration: e.DurationMS, success: e.Success})
 		}
 	}
 	for _, e := range dashboard.TopEscalations {
 		if !e.Timestamp.IsZero() {
-			entries = append(entries, entryWithType{ts: e.Timestamp, logType: "esc", duration: e.DurationMS, success: e.Success})
+			entries = append(entries, entryWithType{ts: e.Timestamp.Time, logType: "esc", duration: e.DurationMS, success: e.Success})
 		}
 	}
 
@@ -565,7 +607,7 @@ func generateTimeSeries(dashboard *domain.DashboardData) []domain.TimeSeriesPoin
 			avgDur = float64(b.totalDurMS) / float64(b.durCount)
 		}
 		points = append(points, domain.TimeSeriesPoint{
-			Timestamp:     ts,
+			Timestamp:     domain.NewTimestamp(ts),
 			APICount:      b.apiCount,
 			SQLCount:      b.sqlCount,
 			FilterCount:   b.filterCount,
@@ -576,7 +618,7 @@ func generateTimeSeries(dashboard *domain.DashboardData) []domain.TimeSeriesPoin
 	}
 
 	sort.Slice(points, func(i, j int) bool {
-		return points[i].Timestamp.Before(points[j].Timestamp)
+		return points[i].Timestamp.Before(points[j].Timestamp.Time)
 	})
 
 	return points
@@ -685,6 +727,26 @@ func generateDistribution(dashboard *domain.DashboardData, parseResult *domain.P
 		dist["by_user"] = byUser
 	}
 
+	// 6. by_client_ip: API calls per client address from JAR aggregates.
+	byClientIP := make(map[string]int)
+	if parseResult != nil && parseResult.JARAggregates != nil && parseResult.JARAggregates.APIByClientIP != nil {
+		for _, g := range parseResult.JARAggregates.APIByClientIP.Groups {
+			if g.EntityName == unknownClientIP {
+				continue
+			}
+			if g.Subtotal != nil {
+				byClientIP[g.EntityName] = g.Subtotal.Total
+			} else {
+				for _, r := range g.Rows {
+					byClientIP[g.EntityName] += r.Total
+				}
+			}
+		}
+	}
+	if len(byClientIP) > 0 {
+		dist["by_client_ip"] = byClientIP
+	}
+
 	// Preserve any existing distribution keys (e.g., "threads", "errors").
 	if dashboard.Distribution != nil {
 		for k, v := range dashboard.Distribution {
@@ -72,6 +72,49 @@ func TestComputeAggregates(t *testing.T) {
 	assert.Len(t, agg.Filter.Groups, 1)
 }
 
+// TestComputeAggregates_ZeroDurations aggregates calls of which 60% were
+// timed at zero: the raw average is dragged down by them, the timed-only
+// metrics are not.
+func TestComputeAggregates_ZeroDurations(t *testing.T) {
+	var calls []domain.TopNEntry
+	for i := range 20 {
+		e := domain.TopNEntry{Form: "HPD:Help Desk", Success: true}
+		if i >= 12 {
+			e.DurationMS = 100 * (i - 11) // 100 to 800
+		}
+		calls = append(calls, e)
+	}
+	agg := computeAggregates(&domain.DashboardData{TopAPICalls: calls})
+
+	require.Len(t, agg.API.Groups, 1)
+	g := agg.API.Groups[0]
+	assert.Equal(t, int64(20), g.Count)
+	assert.Equal(t, int64(3600), g.TotalMS)
+	assert.Equal(t, 180.0, g.AvgMS)
+	assert.Equal(t, int64(0), g.MinMS)
+	assert.InDelta(t, 705.0, g.P95MS, 0.001)
+
+	assert.Equal(t, int64(8), g.TimedCount)
+	assert.Equal(t, 450.0, g.AvgTimedMS)
+	assert.InDelta(t, 765.0, g.P95TimedMS, 0.001)
+
+	require.NotNil(t, agg.API.GrandTotal)
+	assert.Equal(t, int64(8), agg.API.GrandTotal.TimedCount)
+	assert.Equal(t, 450.0, agg.API.GrandTotal.AvgTimedMS)
+}
+
+func TestComputeAggregates_AllZeroDurations(t *testing.T) {
+	agg := computeAggregates(&domain.DashboardData{TopAPICalls: []domain.TopNEntry{
+		{Form: "HPD:Help Desk"}, {Form: "HPD:Help Desk"},
+	}})
+	require.Len(t, agg.API.Groups, 1)
+	g := agg.API.Groups[0]
+	assert.Equal(t, int64(2), g.Count)
+	assert.Zero(t, g.TimedCount)
+	assert.Zero(t, g.AvgTimedMS)
+	assert.Zero(t, g.P95TimedMS)
+}
+
 func TestComputeExceptions(t *testing.T) {
 	dashboard := &domain.DashboardData{
 		GeneralStats: domain.GeneralStatistics{
@@ -238,21 +281,21 @@ func TestGenerateTimeSeries_MinuteBuckets(t *testing.T) {
 
 	dashboard := &domain.DashboardData{
 		TopAPICalls: []domain.TopNEntry{
-			{Timestamp: base, DurationMS: 100, Success: true},
-			{Timestamp: base.Add(30 * time.Second), DurationMS: 200, Success: true},
-			{Timestamp: base.Add(1 * time.Minute), DurationMS: 150, Success: false},
-			{Timestamp: base.Add(1*time.Minute + 45*time.Second), DurationMS: 50, Success: true},
-			{Timestamp: base.Add(3 * time.Minute), DurationMS: 300, Success: true},
+			{Timestamp: domain.NewTimestamp(base), DurationMS: 100, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(30 * time.Second)), DurationMS: 200, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(1 * time.Minute)), DurationMS: 150, Success: false},
+			{Timestamp: domain.NewTimestamp(base.Add(1*time.Minute + 45*time.Second)), DurationMS: 50, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(3 * time.Minute)), DurationMS: 300, Success: true},
 		},
 		TopSQL: []domain.TopNEntry{
-			{Timestamp: base.Add(10 * time.Second), DurationMS: 80, Success: true},
-			{Timestamp: base.Add(2 * time.Minute), DurationMS: 120, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(10 * time.Second)), DurationMS: 80, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(2 * time.Minute)), DurationMS: 120, Success: true},
 		},
 		TopFilters: []domain.TopNEntry{
-			{Timestamp: base, DurationMS: 10, Success: true},
+			{Timestamp: domain.NewTimestamp(base), DurationMS: 10, Success: true},
 		},
 		TopEscalations: []domain.TopNEntry{
-			{Timestamp: base.Add(3 * time.Minute), DurationMS: 500, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(3 * time.Minute)), DurationMS: 500, Success: true},
 		},
 	}
 
@@ -265,12 +308,12 @@ func TestGenerateTimeSeries_MinuteBuckets(t *testing.T) {
 
 	// Verify sorted order.
 	for i := 1; i < len(ts); i++ {
-		assert.True(t, ts[i].Timestamp.After(ts[i-1].Timestamp) || ts[i].Timestamp.Equal(ts[i-1].Timestamp))
+		assert.True(t, ts[i].Timestamp.After(ts[i-1].Timestamp.Time) || ts[i].Timestamp.Equal(ts[i-1].Timestamp.Time))
 	}
 
 	// Bucket at 10:00: 2 API + 1 SQL + 1 filter = 4 entries.
 	b0 := ts[0]
-	assert.Equal(t, base.Truncate(time.Minute), b0.Timestamp)
+	assert.Equal(t, base.Truncate(time.Minute), b0.Timestamp.Time)
 	assert.Equal(t, 2, b0.APICount)
 	assert.Equal(t, 1, b0.SQLCount)
 	assert.Equal(t, 1, b0.FilterCount)
@@ -296,10 +339,10 @@ func TestGenerateTimeSeries_SecondBuckets(t *testing.T) {
 	// Entries span <1 minute → should bucket by second.
 	dashboard := &domain.DashboardData{
 		TopAPICalls: []domain.TopNEntry{
-			{Timestamp: base, DurationMS: 100, Success: true},
-			{Timestamp: base.Add(500 * time.Millisecond), DurationMS: 200, Success: true},
-			{Timestamp: base.Add(1 * time.Second), DurationMS: 150, Success: false},
-			{Timestamp: base.Add(30 * time.Second), DurationMS: 300, Success: true},
+			{Timestamp: domain.NewTimestamp(base), DurationMS: 100, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(500 * time.Millisecond)), DurationMS: 200, Success: true},
+			{Timestamp: domain.NewTimestamp(base.Add(1 * time.Second)), DurationMS: 150, Success: false},
+			{Timestamp: domain.NewTimestamp(base.Add(30 * time.Second)), DurationMS: 300, Success: true},
 		},
 	}
 
@@ -374,6 +417,18 @@ func TestGenerateDistribution(t *testing.T) {
 					},
 				},
 			},
+			APIByClientIP: &domain.JARAggregateTable{
+				Groups: []domain.JARAggregateGroup{
+					{
+						EntityName: "10.11.19.4",
+						Subtotal:   &domain.JARAggregateRow{Total: 115},
+					},
+					{
+						EntityName: "null",
+						Subtotal:   &domain.JARAggregateRow{Total: 5},
+					},
+				},
+			},
 			SQLByTable: &domain.JARAggregateTable{
 				Groups: []domain.JARAggregateGroup{
 					{
@@ -427,6 +482,9 @@ func TestGenerateDistribution(t *testing.T) {
 	assert.Equal(t, 2, dist["by_user"]["alice"])
 	assert.Equal(t, 1, dist["by_user"]["bob"])
 
+	// by_client_ip from JAR aggregates, without calls from no address.
+	assert.Equal(t, map[string]int{"10.11.19.4": 115}, dist["by_client_ip"])
+
 	// Preserved existing distribution keys.
 	assert.Equal(t, 10, dist["threads"]["T001"])
 	assert.Equal(t, 5, dist["threads"]["T002"])
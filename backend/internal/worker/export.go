@@ -0,0 +1,308 @@
+package worker
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+const (
+	// exportPageSize is the number of rows fetched from ClickHouse per query
+	// while streaming an export.
+	exportPageSize = 5000
+
+	// exportCleanupBatch bounds how many expired exports are removed per
+	// cleanup pass.
+	exportCleanupBatch = 100
+)
+
+// exportCSVHeader matches the columns of the synchronous CSV export.
+var exportCSVHeader = []string{"line_number", "timestamp", "log_type", "user", "duration_ms", "success", "form", "raw_text"}
+
+// Exporter writes search results to a gzip-compressed object in S3 so that
+// very large result sets can be downloaded without holding an HTTP stream
+// open for the duration of the query.
+type Exporter struct {
+	pg       storage.PostgresStore
+	ch       storage.ClickHouseStore
+	s3       storage.ObjectStorage
+	nats     streaming.NATSStreamer
+	redis    storage.RedisCache
+	maxRows  int64
+	pageSize int
+}
+
+// NewExporter creates an Exporter. maxRows <= 0 removes the row cap.
+func NewExporter(pg storage.PostgresStore, ch storage.ClickHouseStore, s3 storage.ObjectStorage, nats streaming.NATSStreamer, maxRows int64) *Exporter {
+	return &Exporter{pg: pg, ch: ch, s3: s3, nats: nats, maxRows: maxRows, pageSize: exportPageSize}
+}
+
+// ExportKey builds the tenant-prefixed S3 key for an export object.
+// Format: tenants/{tenantID}/exports/{exportID}.{format}.gz
+func ExportKey(tenantID, exportID, format string) string {
+	return path.Join("tenants", tenantID, "exports", exportID+"."+format+".gz")
+}
+
+// ProcessExport pages through the export's search results, writes them to a
+// compressed temp file and uploads it to S3. Progress is published on the
+// tenant's job progress subject using the export ID as the job ID.
+func (e *Exporter) ProcessExport(ctx context.Context, export domain.SearchExport) error {
+	tenantID := export.TenantID.String()
+	exportID := export.ID.String()
+	logger := slog.With("export_id", exportID, "tenant_id", tenantID, "job_id", export.JobID.String())
+	ctx = storage.WithTenant(ctx, tenantID)
+
+	if err := e.pg.UpdateSearchExportStatus(ctx, export.TenantID, export.ID, domain.ExportStatusRunning, nil); err != nil {
+		return fmt.Errorf("update export status to running: %w", err)
+	}
+	_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, 0, string(domain.ExportStatusRunning), "starting export")
+
+	if export.Format == domain.ExportFormatComparisonHTML || export.Format == domain.ExportFormatComparisonZIP {
+		return e.processComparison(ctx, export)
+	}
+	if export.Format == domain.ExportFormatTenantBundle {
+		return e.processTenantBundle(ctx, export)
+	}
+
+	var q storage.SearchQuery
+	if len(export.Query) > 0 {
+		if err := json.Unmarshal(export.Query, &q); err != nil {
+			return e.failExport(ctx, export, "invalid export query: "+err.Error())
+		}
+	}
+	q.ExportMode = true
+	q.PageSize = e.pageSize
+	if q.SortBy == "" {
+		q.SortBy = "timestamp"
+		q.SortOrder = "asc"
+	}
+
+	job, err := e.pg.GetJob(ctx, export.TenantID, export.JobID)
+	if err != nil {
+		return e.failExport(ctx, export, "load analysis: "+err.Error())
+	}
+	prov, err := e.provenance(ctx, export, job)
+	if err != nil {
+		return e.failExport(ctx, export, "record provenance: "+err.Error())
+	}
+
+	tmpFile, err := os.CreateTemp("", "remedyiq-export-*.gz")
+	if err != nil {
+		return e.failExport(ctx, export, "create temp file: "+err.Error())
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(tmpFile, hash))
+	w := newExportWriter(export.Format, gz)
+	if err := w.begin(prov.Embedded()); err != nil {
+		return e.failExport(ctx, export, "write export: "+err.Error())
+	}
+
+	var written, total int64
+	for page := 1; ; page++ {
+		q.Page = page
+		result, err := e.ch.SearchEntries(ctx, tenantID, export.JobID.String(), q)
+		if err != nil {
+			return e.failExport(ctx, export, "export query failed: "+err.Error())
+		}
+		if page == 1 {
+			total = result.TotalCount
+			if e.maxRows > 0 && total > e.maxRows {
+				total = e.maxRows
+			}
+		}
+
+		entries := result.Entries
+		if remaining := total - written; int64(len(entries)) > remaining {
+			entries = entries[:remaining]
+		}
+		for i := range entries {
+			if err := w.write(&entries[i]); err != nil {
+				return e.failExport(ctx, export, "write export: "+err.Error())
+			}
+		}
+		written += int64(len(entries))
+
+		pct := 100
+		if total > 0 {
+			pct = int(written * 100 / total)
+		}
+		// Hold back 100% until the upload has finished.
+		if pct >= 100 {
+			pct = 99
+		}
+		if err := e.pg.UpdateSearchExportProgress(ctx, export.TenantID, export.ID, pct, written); err != nil {
+			logger.Warn("failed to update export progress", "error", err)
+		}
+		_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, pct, string(domain.ExportStatusRunning),
+			fmt.Sprintf("exported %d of %d rows", written, total))
+
+		if written >= total || len(result.Entries) < q.PageSize {
+			break
+		}
+	}
+
+	if err := w.end(written); err != nil {
+		return e.failExport(ctx, export, "write export: "+err.Error())
+	}
+	if err := gz.Close(); err != nil {
+		return e.failExport(ctx, export, "compress export: "+err.Error())
+	}
+
+	info, err := tmpFile.Stat()
+	if err != nil {
+		return e.failExport(ctx, export, "stat export file: "+err.Error())
+	}
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		return e.failExport(ctx, export, "rewind export file: "+err.Error())
+	}
+
+	key := ExportKey(tenantID, exportID, export.Format)
+	if err := e.s3.Upload(ctx, key, tmpFile, info.Size()); err != nil {
+		return e.failExport(ctx, export, "upload export: "+err.Error())
+	}
+
+	prov.ContentHash = hex.EncodeToString(hash.Sum(nil))
+	if err := e.pg.CompleteSearchExport(ctx, export.TenantID, export.ID, key, written, info.Size(), prov); err != nil {
+		return e.failExport(ctx, export, "record export: "+err.Error())
+	}
+	_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, 100, string(domain.ExportStatusComplete),
+		fmt.Sprintf("export complete: %d rows", written))
+
+	logger.Info("export complete", "rows", written, "size_bytes", info.Size(), "s3_key", key)
+	return nil
+}
+
+// CleanupExpired deletes the S3 objects of exports that expired before now
+// and marks them expired. It returns the number of exports cleaned up.
+func (e *Exporter) CleanupExpired(ctx context.Context, now time.Time) (int, error) {
+	exports, err := e.pg.ListExpiredSearchExports(ctx, now, exportCleanupBatch)
+	if err != nil {
+		return 0, fmt.Errorf("list expired exports: %w", err)
+	}
+
+	cleaned := 0
+	for _, export := range exports {
+		if export.S3Key != nil && *export.S3Key != "" {
+			if err := e.s3.Delete(ctx, *export.S3Key); err != nil {
+				slog.Warn("failed to delete expired export object",
+					"export_id", export.ID.String(), "s3_key", *export.S3Key, "error", err)
+				continue
+			}
+		}
+		if err := e.pg.UpdateSearchExportStatus(ctx, export.TenantID, export.ID, domain.ExportStatusExpired, nil); err != nil {
+			slog.Warn("failed to mark export expired", "export_id", export.ID.String(), "error", err)
+			continue
+		}
+		cleaned++
+	}
+	return cleaned, nil
+}
+
+func (e *Exporter) failExport(ctx context.Context, export domain.SearchExport, errMsg string) error {
+	slog.Error("export failed", "export_id", export.ID.String(), "error", errMsg)
+	_ = e.pg.UpdateSearchExportStatus(ctx, export.TenantID, export.ID, domain.ExportStatusFailed, &errMsg)
+	_ = e.nats.PublishJobProgress(ctx, export.TenantID.String(), export.ID.String(), 0, string(domain.ExportStatusFailed), errMsg)
+	return fmt.Errorf("%s", errMsg)
+}
+
+// exportWriter serializes log entries incrementally in one export format.
+// The export's provenance heads the output.
+type exportWriter interface {
+	begin(prov domain.ArtifactProvenance) error
+	write(e *domain.LogEntry) error
+	end(count int64) error
+}
+
+func newExportWriter(format string, w io.Writer) exportWriter {
+	if format == "json" {
+		return &jsonExportWriter{w: w}
+	}
+	return &csvExportWriter{out: w, w: csv.NewWriter(w)}
+}
+
+type csvExportWriter struct {
+	out io.Writer
+	w   *csv.Writer
+}
+
+// begin writes the provenance as "# name: value" comment lines ahead of
+// the header row.
+func (c *csvExportWriter) begin(prov domain.ArtifactProvenance) error {
+	for _, f := range prov.Fields() {
+		if _, err := fmt.Fprintf(c.out, "# %s: %s\n", f.Name, f.Value); err != nil {
+			return err
+		}
+	}
+	return c.w.Write(exportCSVHeader)
+}
+
+func (c *csvExportWriter) write(e *domain.LogEntry) error {
+	return c.w.Write([]string{
+		fmt.Sprintf("%d", e.LineNumber),
+		e.Timestamp.Format(time.RFC3339),
+		string(e.LogType),
+		e.User,
+		fmt.Sprintf("%d", e.DurationMS),
+		fmt.Sprintf("%t", e.Success),
+		e.Form,
+		e.RawText,
+	})
+}
+
+func (c *csvExportWriter) end(int64) error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonExportWriter produces the same {"entries": [...], "count": N} document
+// as the synchronous JSON export, written one entry at a time, with the
+// export's provenance under "provenance".
+type jsonExportWriter struct {
+	w     io.Writer
+	wrote bool
+}
+
+func (j *jsonExportWriter) begin(prov domain.ArtifactProvenance) error {
+	data, err := json.Marshal(prov)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(j.w, `{"provenance":%s,"entries":[`, data)
+	return err
+}
+
+func (j *jsonExportWriter) write(e *domain.LogEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if j.wrote {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.wrote = true
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonExportWriter) end(count int64) error {
+	_, err := fmt.Fprintf(j.w, `],"count":%d}`, count)
+	return err
+}
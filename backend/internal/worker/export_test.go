@@ -0,0 +1,358 @@
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func newTestExport(t *testing.T, format string, q storage.SearchQuery) domain.SearchExport {
+	t.Helper()
+	raw, err := json.Marshal(q)
+	require.NoError(t, err)
+	return domain.SearchExport{
+		ID:        uuid.New(),
+		TenantID:  uuid.New(),
+		JobID:     uuid.New(),
+		UserID:    "user-1",
+		Status:    domain.ExportStatusQueued,
+		Format:    format,
+		Query:     raw,
+		CreatedAt: time.Date(2026, 2, 4, 8, 0, 0, 123, time.UTC),
+	}
+}
+
+// expectExportJob expects the export's analysis to be loaded for its
+// provenance and returns it.
+func expectExportJob(pg *testutil.MockPostgresStore, export domain.SearchExport) *domain.AnalysisJob {
+	completed := time.Date(2026, 2, 3, 11, 0, 0, 0, time.UTC)
+	job := &domain.AnalysisJob{ID: export.JobID, TenantID: export.TenantID, Status: domain.JobStatusComplete, CompletedAt: &completed}
+	pg.On("GetJob", mock.Anything, export.TenantID, export.JobID).Return(job, nil)
+	return job
+}
+
+func exportEntries(n int) []domain.LogEntry {
+	entries := make([]domain.LogEntry, n)
+	base := time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+	for i := range entries {
+		entries[i] = domain.LogEntry{
+			LineNumber: uint32(i + 1),
+			Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Second)),
+			LogType:    domain.LogTypeAPI,
+			User:       "Demo",
+			Success:    true,
+			RawText:    "line",
+		}
+	}
+	return entries
+}
+
+// captureUpload records the decompressed body of the object passed to Upload.
+func captureUpload(s3 *testutil.MockObjectStorage, key string, body *[]byte) {
+	s3.On("Upload", mock.Anything, key, mock.Anything, mock.AnythingOfType("int64")).
+		Run(func(args mock.Arguments) {
+			gz, err := gzip.NewReader(args.Get(2).(io.Reader))
+			if err != nil {
+				return
+			}
+			*body, _ = io.ReadAll(gz)
+		}).
+		Return(nil)
+}
+
+func TestExporter_ProcessExport_CSVLifecycle(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+
+	export := newTestExport(t, "csv", storage.SearchQuery{Query: "user:Demo"})
+	tenantID, exportID, jobID := export.TenantID.String(), export.ID.String(), export.JobID.String()
+	key := ExportKey(tenantID, exportID, "csv")
+
+	e := NewExporter(pg, ch, s3, nats, 0)
+	e.pageSize = 2
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, export.TenantID, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, tenantID, exportID, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	all := exportEntries(3)
+	pageQuery := func(page int) interface{} {
+		return mock.MatchedBy(func(q storage.SearchQuery) bool {
+			return q.Page == page && q.PageSize == 2 && q.ExportMode && q.Query == "user:Demo" && q.SortBy == "timestamp"
+		})
+	}
+	ch.On("SearchEntries", mock.Anything, tenantID, jobID, pageQuery(1)).
+		Return(&storage.SearchResult{Entries: all[:2], TotalCount: 3}, nil).Once()
+	ch.On("SearchEntries", mock.Anything, tenantID, jobID, pageQuery(2)).
+		Return(&storage.SearchResult{Entries: all[2:], TotalCount: 3}, nil).Once()
+
+	pg.On("UpdateSearchExportProgress", mock.Anything, export.TenantID, export.ID, 66, int64(2)).Return(nil).Once()
+	pg.On("UpdateSearchExportProgress", mock.Anything, export.TenantID, export.ID, 99, int64(3)).Return(nil).Once()
+
+	expectExportJob(pg, export)
+	var body []byte
+	captureUpload(s3, key, &body)
+	pg.On("CompleteSearchExport", mock.Anything, export.TenantID, export.ID, key, int64(3), mock.AnythingOfType("int64"),
+		mock.MatchedBy(func(p *domain.ArtifactProvenance) bool { return len(p.ContentHash) == 64 })).Return(nil)
+
+	require.NoError(t, e.ProcessExport(context.Background(), export))
+
+	assert.True(t, strings.HasPrefix(string(body), "# format: csv\n# query: {"), "provenance heads the file:\n%s", body)
+	assert.Contains(t, string(body), "# analysis: "+jobID+" completed 2026-02-03T11:00:00Z\n")
+	assert.Contains(t, string(body), "# parser_version: "+ParserVersion+"\n# generated_by: user-1\n# generated_at: 2026-02-04T08:00:00Z\n")
+	assert.NotContains(t, string(body), "content_hash")
+
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4, "header plus three rows")
+	assert.Equal(t, exportCSVHeader, rows[0])
+	assert.Equal(t, "3", rows[3][0])
+
+	nats.AssertCalled(t, "PublishJobProgress", mock.Anything, tenantID, exportID, 100, string(domain.ExportStatusComplete), "export complete: 3 rows")
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	s3.AssertExpectations(t)
+}
+
+func TestExporter_ProcessExport_JSONRespectsRowCap(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+
+	export := newTestExport(t, "json", storage.SearchQuery{})
+	tenantID, exportID := export.TenantID.String(), export.ID.String()
+	key := ExportKey(tenantID, exportID, "json")
+
+	e := NewExporter(pg, ch, s3, nats, 2)
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, export.TenantID, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, tenantID, exportID, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ch.On("SearchEntries", mock.Anything, tenantID, export.JobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(&storage.SearchResult{Entries: exportEntries(5), TotalCount: 5}, nil).Once()
+	pg.On("UpdateSearchExportProgress", mock.Anything, export.TenantID, export.ID, 99, int64(2)).Return(nil)
+
+	expectExportJob(pg, export)
+	var body []byte
+	captureUpload(s3, key, &body)
+	pg.On("CompleteSearchExport", mock.Anything, export.TenantID, export.ID, key, int64(2), mock.AnythingOfType("int64"),
+		mock.AnythingOfType("*domain.ArtifactProvenance")).Return(nil)
+
+	require.NoError(t, e.ProcessExport(context.Background(), export))
+
+	var doc struct {
+		Provenance domain.ArtifactProvenance `json:"provenance"`
+		Entries    []domain.LogEntry         `json:"entries"`
+		Count      int                       `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(body, &doc))
+	assert.Equal(t, 2, doc.Count)
+	assert.Len(t, doc.Entries, 2)
+	require.Len(t, doc.Provenance.Analyses, 1)
+	assert.Equal(t, export.JobID, doc.Provenance.Analyses[0].JobID)
+	assert.Equal(t, "json", doc.Provenance.Format)
+	assert.Empty(t, doc.Provenance.ContentHash)
+	ch.AssertNumberOfCalls(t, "SearchEntries", 1)
+}
+
+func TestExporter_ProcessExport_QueryFailureMarksFailed(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+
+	export := newTestExport(t, "csv", storage.SearchQuery{})
+	tenantID, exportID := export.TenantID.String(), export.ID.String()
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, export.TenantID, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, tenantID, exportID, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	expectExportJob(pg, export)
+	ch.On("SearchEntries", mock.Anything, tenantID, export.JobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(nil, errors.New("clickhouse unavailable"))
+	pg.On("UpdateSearchExportStatus", mock.Anything, export.TenantID, export.ID, domain.ExportStatusFailed,
+		mock.MatchedBy(func(msg *string) bool { return msg != nil && *msg == "export query failed: clickhouse unavailable" })).Return(nil)
+
+	err := NewExporter(pg, ch, s3, nats, 0).ProcessExport(context.Background(), export)
+	require.Error(t, err)
+
+	nats.AssertCalled(t, "PublishJobProgress", mock.Anything, tenantID, exportID, 0, string(domain.ExportStatusFailed), mock.Anything)
+	s3.AssertNotCalled(t, "Upload", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	pg.AssertExpectations(t)
+}
+
+func TestExporter_CleanupExpired(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	s3 := &testutil.MockObjectStorage{}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	okKey, badKey := "tenants/t/exports/a.csv.gz", "tenants/t/exports/b.csv.gz"
+	withObject := domain.SearchExport{ID: uuid.New(), TenantID: uuid.New(), S3Key: &okKey}
+	failedNoObject := domain.SearchExport{ID: uuid.New(), TenantID: uuid.New()}
+	deleteFails := domain.SearchExport{ID: uuid.New(), TenantID: uuid.New(), S3Key: &badKey}
+
+	pg.On("ListExpiredSearchExports", mock.Anything, now, exportCleanupBatch).
+		Return([]domain.SearchExport{withObject, failedNoObject, deleteFails}, nil)
+	s3.On("Delete", mock.Anything, okKey).Return(nil)
+	s3.On("Delete", mock.Anything, badKey).Return(errors.New("access denied"))
+	pg.On("UpdateSearchExportStatus", mock.Anything, withObject.TenantID, withObject.ID, domain.ExportStatusExpired, (*string)(nil)).Return(nil)
+	pg.On("UpdateSearchExportStatus", mock.Anything, failedNoObject.TenantID, failedNoObject.ID, domain.ExportStatusExpired, (*string)(nil)).Return(nil)
+
+	n, err := NewExporter(pg, nil, s3, nil, 0).CleanupExpired(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "export whose object could not be deleted stays pending")
+
+	pg.AssertExpectations(t)
+	s3.AssertExpectations(t)
+}
+
+func TestExportKey(t *testing.T) {
+	assert.Equal(t, "tenants/t1/exports/e1.csv.gz", ExportKey("t1", "e1", "csv"))
+}
+
+func TestExporter_ProcessExport_Comparison(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+
+	tenant := uuid.New()
+	baseline := &domain.AnalysisJob{ID: uuid.New(), TenantID: tenant, FileID: uuid.New(), Status: domain.JobStatusComplete}
+	candidate := &domain.AnalysisJob{ID: uuid.New(), TenantID: tenant, FileID: uuid.New(), Status: domain.JobStatusComplete}
+	raw, err := json.Marshal(domain.ComparisonExportQuery{
+		BaselineJobID:  baseline.ID,
+		CandidateJobID: candidate.ID,
+		Sections:       []domain.ComparisonSection{domain.ComparisonQueues},
+	})
+	require.NoError(t, err)
+	export := domain.SearchExport{ID: uuid.New(), TenantID: tenant, JobID: candidate.ID, Format: domain.ExportFormatComparisonHTML, Query: raw}
+	tenantID, exportID := tenant.String(), export.ID.String()
+	key := ComparisonExportKey(tenantID, exportID, export.Format)
+	assert.Equal(t, "tenants/"+tenantID+"/exports/"+exportID+".html", key)
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, tenant, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, tenantID, exportID, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	pg.On("GetJob", mock.Anything, tenant, baseline.ID).Return(baseline, nil)
+	pg.On("GetJob", mock.Anything, tenant, candidate.ID).Return(candidate, nil)
+	pg.On("GetLogFile", mock.Anything, tenant, mock.Anything).Return(&domain.LogFile{Filename: "arserver.log"}, nil)
+	ch.On("GetGaps", mock.Anything, tenantID, baseline.ID.String()).Return(&domain.GapsResponse{}, nil)
+	ch.On("GetGaps", mock.Anything, tenantID, candidate.ID.String()).Return(&domain.GapsResponse{
+		QueueHealth: []domain.QueueHealthSummary{{Queue: "Fast", TotalCalls: 12}},
+	}, nil)
+
+	var body []byte
+	s3.On("Upload", mock.Anything, key, mock.Anything, mock.AnythingOfType("int64")).
+		Run(func(args mock.Arguments) { body, _ = io.ReadAll(args.Get(2).(io.Reader)) }).
+		Return(nil)
+	var prov *domain.ArtifactProvenance
+	pg.On("CompleteSearchExport", mock.Anything, tenant, export.ID, key, int64(1), mock.AnythingOfType("int64"), mock.Anything).
+		Run(func(args mock.Arguments) { prov = args.Get(6).(*domain.ArtifactProvenance) }).
+		Return(nil)
+
+	require.NoError(t, NewExporter(pg, ch, s3, nats, 0).ProcessExport(context.Background(), export))
+
+	assert.Contains(t, string(body), "<h2>Queue health</h2>")
+	assert.Contains(t, string(body), "<td>Fast</td>")
+	assert.Contains(t, string(body), "<tr><td>analysis</td><td>"+candidate.ID.String()+"</td></tr>")
+	require.NotNil(t, prov)
+	sum := sha256.Sum256(body)
+	assert.Equal(t, hex.EncodeToString(sum[:]), prov.ContentHash)
+	assert.Equal(t, []uuid.UUID{baseline.ID, candidate.ID}, prov.Summary().AnalysisIDs)
+	nats.AssertCalled(t, "PublishJobProgress", mock.Anything, tenantID, exportID, 100, string(domain.ExportStatusComplete), "comparison export complete: 1 sections")
+	pg.AssertExpectations(t)
+	s3.AssertExpectations(t)
+	ch.AssertNotCalled(t, "SearchEntries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExporter_ProcessExport_ComparisonMissingJobFails(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+
+	raw, err := json.Marshal(domain.ComparisonExportQuery{BaselineJobID: uuid.New(), CandidateJobID: uuid.New()})
+	require.NoError(t, err)
+	export := domain.SearchExport{ID: uuid.New(), TenantID: uuid.New(), Format: domain.ExportFormatComparisonZIP, Query: raw}
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, export.TenantID, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	pg.On("GetJob", mock.Anything, export.TenantID, mock.Anything).Return(nil, errors.New("postgres: job not found"))
+	pg.On("UpdateSearchExportStatus", mock.Anything, export.TenantID, export.ID, domain.ExportStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+
+	err = NewExporter(pg, &testutil.MockClickHouseStore{}, &testutil.MockObjectStorage{}, nats, 0).ProcessExport(context.Background(), export)
+	assert.EqualError(t, err, "load baseline analysis: postgres: job not found")
+	pg.AssertExpectations(t)
+}
+
+func TestExporter_ProcessExport_RegenerationIsByteIdentical(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+
+	source := newTestExport(t, "csv", storage.SearchQuery{Query: "user:Demo", SortBy: "user", SortOrder: "desc"})
+	tenantID := source.TenantID.String()
+	// A regeneration is requested by someone else, later, from the source
+	// row, whose JSONB query comes back reordered.
+	regen := source
+	regen.ID = uuid.New()
+	regen.UserID = "user-2"
+	regen.CreatedAt = source.CreatedAt.Add(72 * time.Hour)
+	regen.RegeneratedFrom = &source.ID
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(source.Query, &fields))
+	reordered, err := json.MarshalIndent(fields, "", " ")
+	require.NoError(t, err)
+	require.NotEqual(t, string(source.Query), string(reordered))
+	regen.Query = reordered
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, source.TenantID, mock.Anything, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	pg.On("UpdateSearchExportProgress", mock.Anything, source.TenantID, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, tenantID, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	expectExportJob(pg, source)
+	ch.On("SearchEntries", mock.Anything, tenantID, source.JobID.String(), mock.AnythingOfType("storage.SearchQuery")).
+		Return(&storage.SearchResult{Entries: exportEntries(3), TotalCount: 3}, nil)
+
+	objects := make(map[string][]byte)
+	s3.On("Upload", mock.Anything, mock.Anything, mock.Anything, mock.AnythingOfType("int64")).
+		Run(func(args mock.Arguments) { objects[args.String(1)], _ = io.ReadAll(args.Get(2).(io.Reader)) }).
+		Return(nil)
+	provs := make(map[uuid.UUID]*domain.ArtifactProvenance)
+	pg.On("CompleteSearchExport", mock.Anything, source.TenantID, mock.Anything, mock.Anything, int64(3), mock.AnythingOfType("int64"), mock.Anything).
+		Run(func(args mock.Arguments) { provs[args.Get(2).(uuid.UUID)] = args.Get(6).(*domain.ArtifactProvenance) }).
+		Return(nil)
+
+	e := NewExporter(pg, ch, s3, nats, 0)
+	require.NoError(t, e.ProcessExport(context.Background(), source))
+	stored := source
+	stored.Provenance = provs[source.ID]
+	pg.On("GetSearchExport", mock.Anything, source.TenantID, source.ID).Return(&stored, nil)
+	require.NoError(t, e.ProcessExport(context.Background(), regen))
+
+	first := objects[ExportKey(tenantID, source.ID.String(), "csv")]
+	second := objects[ExportKey(tenantID, regen.ID.String(), "csv")]
+	require.NotEmpty(t, first)
+	assert.Equal(t, first, second)
+	sum := sha256.Sum256(second)
+	assert.Equal(t, hex.EncodeToString(sum[:]), provs[regen.ID].ContentHash)
+	assert.Equal(t, provs[source.ID], provs[regen.ID])
+	assert.Equal(t, "user-1", provs[regen.ID].GeneratedBy)
+}
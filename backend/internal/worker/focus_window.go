@@ -0,0 +1,254 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// DefaultFocusWindowMinWidth and DefaultFocusWindowMaxWidth bound the
+	// width of a focus window. A window is never narrower than one bucket
+	// of the capture's histogram.
+	DefaultFocusWindowMinWidth = 5 * time.Minute
+	DefaultFocusWindowMaxWidth = 2 * time.Hour
+
+	// focusThreshold is the bucket score above which a bucket stands out
+	// from the capture's baseline.
+	focusThreshold = 1.0
+
+	// focusSignalDeviation is the deviation from which a metric is named
+	// as a signal of the window.
+	focusSignalDeviation = 3.0
+
+	// focusMaxDeviation caps the deviation of one metric so that a single
+	// extreme bucket does not outweigh a sustained incident.
+	focusMaxDeviation = 10.0
+
+	// focusMinErrorEntries is the number of entries a bucket needs for its
+	// error rate to count; the error rate of a few entries is noise.
+	focusMinErrorEntries = 10
+)
+
+// focusWeights weigh the deviations of the error rate, the p95 duration
+// and the queue time into a bucket's score.
+var focusWeights = [3]float64{0.5, 0.3, 0.2}
+
+var focusSignals = [3]string{domain.FocusSignalErrorRate, domain.FocusSignalP95Duration, domain.FocusSignalQueueTime}
+
+// SetFocusWindowWidth bounds the width of the focus windows computed for
+// completed jobs. Non-positive values keep the defaults.
+func (p *Pipeline) SetFocusWindowWidth(minWidth, maxWidth time.Duration) {
+	p.focusMinWidth = minWidth
+	p.focusMaxWidth = maxWidth
+}
+
+func (p *Pipeline) focusWindowWidth() (time.Duration, time.Duration) {
+	minWidth, maxWidth := p.focusMinWidth, p.focusMaxWidth
+	if minWidth <= 0 {
+		minWidth = DefaultFocusWindowMinWidth
+	}
+	if maxWidth <= 0 {
+		maxWidth = DefaultFocusWindowMaxWidth
+	}
+	return minWidth, max(minWidth, maxWidth)
+}
+
+// selectFocusWindow scores every bucket of a capture by how far its error
+// rate, p95 duration and queue time rise above the capture's median, and
+// returns the contiguous run of buckets between minWidth and maxWidth wide
+// that stands out the most. It returns nil when no bucket stands out or
+// when the window would span the whole capture, since the full range is
+// then the better default.
+func selectFocusWindow(metrics *domain.FocusMetrics, minWidth, maxWidth time.Duration) *domain.FocusWindow {
+	if metrics == nil || metrics.BucketMS <= 0 || len(metrics.Buckets) < 2 {
+		return nil
+	}
+	bucket := time.Duration(metrics.BucketMS) * time.Millisecond
+	buckets := densifyFocusBuckets(metrics.Buckets, bucket)
+	n := len(buckets)
+
+	deviations := focusDeviations(buckets)
+	scores := make([]float64, n)
+	standsOut := false
+	for i, devs := range deviations {
+		for m, d := range devs {
+			scores[i] += focusWeights[m] * d
+		}
+		standsOut = standsOut || scores[i] > focusThreshold
+	}
+	if !standsOut {
+		return nil
+	}
+
+	minBuckets := max(1, int(math.Ceil(float64(minWidth)/float64(bucket))))
+	maxBuckets := max(minBuckets, int(maxWidth/bucket))
+	minBuckets, maxBuckets = min(minBuckets, n), min(maxBuckets, n)
+
+	// Pick the run maximizing the sum of excess scores, so that buckets
+	// below the threshold only join a window to bridge or pad it.
+	prefix := make([]float64, n+1)
+	for i, s := range scores {
+		prefix[i+1] = prefix[i] + s - focusThreshold
+	}
+	bestStart, bestLen, best := 0, 0, math.Inf(-1)
+	for start := 0; start < n; start++ {
+		for length := minBuckets; length <= maxBuckets && start+length <= n; length++ {
+			if sum := prefix[start+length] - prefix[start]; sum > best {
+				bestStart, bestLen, best = start, length, sum
+			}
+		}
+	}
+	if bestLen == 0 || bestLen == n {
+		return nil
+	}
+
+	window := &domain.FocusWindow{
+		Start:     buckets[bestStart].Start,
+		End:       domain.NewTimestamp(buckets[bestStart+bestLen-1].Start.Add(bucket)),
+		FullStart: metrics.RangeStart,
+		FullEnd:   metrics.RangeEnd,
+		BucketMS:  metrics.BucketMS,
+		Signals:   []string{},
+	}
+	if window.Start.Before(metrics.RangeStart.Time) {
+		window.Start = metrics.RangeStart
+	}
+	if !metrics.RangeEnd.IsZero() && window.End.After(metrics.RangeEnd.Time) {
+		window.End = metrics.RangeEnd
+	}
+
+	var total float64
+	var peak [3]float64
+	for i := bestStart; i < bestStart+bestLen; i++ {
+		total += scores[i]
+		for m, d := range deviations[i] {
+			peak[m] = max(peak[m], d)
+		}
+	}
+	window.Score = math.Round(total/float64(bestLen)*100) / 100
+	for m, d := range peak {
+		if d >= focusSignalDeviation {
+			window.Signals = append(window.Signals, focusSignals[m])
+		}
+	}
+	return window
+}
+
+// densifyFocusBuckets fills the buckets missing between the first and the
+// last non-empty bucket with empty ones, so that windows are measured in
+// time rather than in buckets.
+func densifyFocusBuckets(buckets []domain.FocusBucket, bucket time.Duration) []domain.FocusBucket {
+	first, last := buckets[0].Start.Time, buckets[len(buckets)-1].Start.Time
+	dense := make([]domain.FocusBucket, 0, int(last.Sub(first)/bucket)+1)
+	next := 0
+	for at := first; !at.After(last); at = at.Add(bucket) {
+		if next < len(buckets) && !buckets[next].Start.After(at) {
+			dense = append(dense, buckets[next])
+			next++
+			continue
+		}
+		dense = append(dense, domain.FocusBucket{Start: domain.NewTimestamp(at)})
+	}
+	return dense
+}
+
+// focusDeviations returns, per bucket, how many robust standard deviations
+// its error rate, p95 duration and queue time lie above the median of the
+// capture's non-empty buckets. Only rises count; empty buckets have none.
+func focusDeviations(buckets []domain.FocusBucket) [][3]float64 {
+	var values [3][]float64
+	for _, b := range buckets {
+		if b.Entries == 0 {
+			continue
+		}
+		if b.Entries >= focusMinErrorEntries {
+			values[0] = append(values[0], float64(b.Errors)/float64(b.Entries))
+		}
+		values[1] = append(values[1], b.P95MS)
+		values[2] = append(values[2], b.AvgQueueMS)
+	}
+
+	// The floors keep a near-constant metric from turning noise into
+	// deviations: a point of error rate, a tenth of the median duration or
+	// 10ms, whichever is larger.
+	var medians, scales [3]float64
+	for m := range values {
+		medians[m] = median(values[m])
+		floor := 0.01
+		if m > 0 {
+			floor = max(medians[m]/10, 10)
+		}
+		scales[m] = max(1.4826*medianAbsDeviation(values[m], medians[m]), floor)
+	}
+
+	deviations := make([][3]float64, len(buckets))
+	for i, b := range buckets {
+		if b.Entries == 0 {
+			continue
+		}
+		observed := [3]float64{0, b.P95MS, b.AvgQueueMS}
+		if b.Entries >= focusMinErrorEntries {
+			observed[0] = float64(b.Errors) / float64(b.Entries)
+		} else {
+			observed[0] = medians[0]
+		}
+		for m, v := range observed {
+			deviations[i][m] = min(max((v-medians[m])/scales[m], 0), focusMaxDeviation)
+		}
+	}
+	return deviations
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+func medianAbsDeviation(values []float64, center float64) float64 {
+	abs := make([]float64, len(values))
+	for i, v := range values {
+		abs[i] = math.Abs(v - center)
+	}
+	return median(abs)
+}
+
+// recordFocusWindow scores the buckets of the job's capture and records
+// the window the dashboard should open on with the job. Failures are
+// logged and otherwise ignored; the dashboard then opens on the full range.
+func (p *Pipeline) recordFocusWindow(ctx context.Context, job *domain.AnalysisJob, stats domain.GeneralStatistics) {
+	from, to := stats.LogStart.Time, stats.LogEnd.Time
+	if from.IsZero() || !to.After(from) {
+		return
+	}
+	logger := slog.With("job_id", job.ID, "tenant_id", job.TenantID)
+	metrics, err := p.ch.GetFocusMetrics(ctx, job.TenantID.String(), job.ID.String(), from, to)
+	if err != nil {
+		logger.Warn("focus window computation failed (non-fatal)", "error", err)
+		return
+	}
+	minWidth, maxWidth := p.focusWindowWidth()
+	window := selectFocusWindow(metrics, minWidth, maxWidth)
+	if window == nil {
+		return
+	}
+	window.ComputedAt = domain.NewTimestamp(p.now())
+	if err := p.pg.UpdateJobFocusWindow(ctx, job.TenantID, job.ID, window); err != nil {
+		logger.Warn("failed to record focus window", "error", err)
+		return
+	}
+	job.FocusWindow = window
+	logger.Info("focus window recorded", "start", window.Start, "end", window.End, "score", window.Score, "signals", window.Signals)
+}
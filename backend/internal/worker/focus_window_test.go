@@ -0,0 +1,197 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var focusBase = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+// focusProfile is a 24-hour capture in 15-minute buckets whose activity
+// varies a little around a steady baseline.
+func focusProfile() *domain.FocusMetrics {
+	m := &domain.FocusMetrics{
+		RangeStart: domain.NewTimestamp(focusBase),
+		RangeEnd:   domain.NewTimestamp(focusBase.Add(24 * time.Hour)),
+		BucketSize: "15 MINUTE",
+		BucketMS:   (15 * time.Minute).Milliseconds(),
+	}
+	for i := range 96 {
+		m.Buckets = append(m.Buckets, domain.FocusBucket{
+			Start:      domain.NewTimestamp(focusBase.Add(time.Duration(i) * 15 * time.Minute)),
+			Entries:    int64(1000 + 20*(i%5)),
+			Errors:     int64(4 + i%3),
+			P95MS:      float64(200 + 10*(i%4)),
+			AvgQueueMS: float64(20 + i%3),
+		})
+	}
+	return m
+}
+
+func TestSelectFocusWindow(t *testing.T) {
+	at := func(bucket int) domain.Timestamp {
+		return domain.NewTimestamp(focusBase.Add(time.Duration(bucket) * 15 * time.Minute))
+	}
+
+	t.Run("flat profile", func(t *testing.T) {
+		assert.Nil(t, selectFocusWindow(focusProfile(), 5*time.Minute, 2*time.Hour))
+	})
+
+	t.Run("single spike", func(t *testing.T) {
+		m := focusProfile()
+		for i := 40; i < 42; i++ {
+			m.Buckets[i].Errors = 300
+			m.Buckets[i].P95MS = 4000
+		}
+		w := selectFocusWindow(m, 5*time.Minute, 2*time.Hour)
+		require.NotNil(t, w)
+		assert.Equal(t, at(40), w.Start)
+		assert.Equal(t, at(42), w.End)
+		assert.Equal(t, m.RangeStart, w.FullStart)
+		assert.Equal(t, m.RangeEnd, w.FullEnd)
+		assert.Equal(t, []string{domain.FocusSignalErrorRate, domain.FocusSignalP95Duration}, w.Signals)
+		assert.Greater(t, w.Score, focusThreshold)
+	})
+
+	t.Run("multiple spikes pick the strongest", func(t *testing.T) {
+		m := focusProfile()
+		m.Buckets[10].AvgQueueMS = 900
+		for i := 70; i < 74; i++ {
+			m.Buckets[i].Errors = 200
+		}
+		w := selectFocusWindow(m, 5*time.Minute, 2*time.Hour)
+		require.NotNil(t, w)
+		assert.Equal(t, at(70), w.Start)
+		assert.Equal(t, at(74), w.End)
+		assert.Equal(t, []string{domain.FocusSignalErrorRate}, w.Signals)
+	})
+
+	t.Run("incident at the start", func(t *testing.T) {
+		m := focusProfile()
+		for i := range 3 {
+			m.Buckets[i].P95MS = 9000
+			m.Buckets[i].AvgQueueMS = 1500
+		}
+		w := selectFocusWindow(m, 5*time.Minute, 2*time.Hour)
+		require.NotNil(t, w)
+		assert.Equal(t, m.RangeStart, w.Start)
+		assert.Equal(t, at(3), w.End)
+		assert.Equal(t, []string{domain.FocusSignalP95Duration, domain.FocusSignalQueueTime}, w.Signals)
+	})
+
+	t.Run("width bounds", func(t *testing.T) {
+		m := focusProfile()
+		for i := 30; i < 50; i++ {
+			m.Buckets[i].Errors = 300
+		}
+		w := selectFocusWindow(m, 5*time.Minute, 2*time.Hour)
+		require.NotNil(t, w)
+		assert.Equal(t, 2*time.Hour, w.End.Sub(w.Start.Time), "capped at the maximum width")
+
+		m = focusProfile()
+		m.Buckets[60].Errors = 300
+		w = selectFocusWindow(m, time.Hour, 2*time.Hour)
+		require.NotNil(t, w)
+		assert.Equal(t, time.Hour, w.End.Sub(w.Start.Time), "padded to the minimum width")
+		assert.False(t, w.Start.After(at(60).Time))
+		assert.False(t, w.End.Before(at(61).Time))
+	})
+
+	t.Run("gaps in the capture count as time", func(t *testing.T) {
+		m := focusProfile()
+		m.Buckets = append(m.Buckets[:20:20], m.Buckets[60:]...) // ten hours without entries
+		m.Buckets[20].Errors = 300
+		w := selectFocusWindow(m, 5*time.Minute, 2*time.Hour)
+		require.NotNil(t, w)
+		assert.Equal(t, at(60), w.Start)
+		assert.Equal(t, at(61), w.End)
+	})
+
+	t.Run("a window spanning the capture is no focus", func(t *testing.T) {
+		m := focusProfile()
+		m.Buckets = m.Buckets[:4]
+		m.Buckets[1].Errors = 300
+		assert.Nil(t, selectFocusWindow(m, time.Hour, 2*time.Hour))
+	})
+
+	t.Run("no buckets", func(t *testing.T) {
+		assert.Nil(t, selectFocusWindow(nil, 5*time.Minute, 2*time.Hour))
+		assert.Nil(t, selectFocusWindow(&domain.FocusMetrics{BucketMS: 60000}, 5*time.Minute, 2*time.Hour))
+	})
+}
+
+func TestPipeline_FocusWindowWidthDefaults(t *testing.T) {
+	p := NewPipeline(nil, nil, nil, nil, nil, nil, nil)
+	minWidth, maxWidth := p.focusWindowWidth()
+	assert.Equal(t, DefaultFocusWindowMinWidth, minWidth)
+	assert.Equal(t, DefaultFocusWindowMaxWidth, maxWidth)
+
+	p.SetFocusWindowWidth(30*time.Minute, 10*time.Minute)
+	minWidth, maxWidth = p.focusWindowWidth()
+	assert.Equal(t, 30*time.Minute, minWidth)
+	assert.Equal(t, 30*time.Minute, maxWidth, "never below the minimum")
+}
+
+func TestRecordFocusWindow(t *testing.T) {
+	job := newTestJob()
+	stats := domain.GeneralStatistics{
+		LogStart: domain.NewTimestamp(focusBase),
+		LogEnd:   domain.NewTimestamp(focusBase.Add(24 * time.Hour)),
+	}
+
+	t.Run("recorded", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		m := focusProfile()
+		m.Buckets[50].Errors = 400
+		ch.On("GetFocusMetrics", mock.Anything, job.TenantID.String(), job.ID.String(), focusBase, focusBase.Add(24*time.Hour)).Return(m, nil)
+		pg.On("UpdateJobFocusWindow", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FocusWindow")).Return(nil)
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		j := job
+		p.recordFocusWindow(context.Background(), &j, stats)
+		require.NotNil(t, j.FocusWindow)
+		assert.Equal(t, m.Buckets[50].Start, j.FocusWindow.Start)
+		assert.False(t, j.FocusWindow.ComputedAt.IsZero())
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("nothing stands out", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		ch.On("GetFocusMetrics", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything, mock.Anything).Return(focusProfile(), nil)
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		j := job
+		p.recordFocusWindow(context.Background(), &j, stats)
+		assert.Nil(t, j.FocusWindow)
+		pg.AssertNotCalled(t, "UpdateJobFocusWindow", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("query failure is non-fatal", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		ch.On("GetFocusMetrics", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything, mock.Anything).Return(nil, errors.New("ch down"))
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		j := job
+		p.recordFocusWindow(context.Background(), &j, stats)
+		assert.Nil(t, j.FocusWindow)
+	})
+
+	t.Run("no capture range", func(t *testing.T) {
+		p := NewPipeline(nil, &testutil.MockClickHouseStore{}, nil, nil, nil, nil, nil)
+		j := job
+		p.recordFocusWindow(context.Background(), &j, domain.GeneralStatistics{})
+		assert.Nil(t, j.FocusWindow)
+	})
+}
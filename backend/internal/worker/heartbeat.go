@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// HeartbeatInterval is how often a worker records that it is alive.
+	HeartbeatInterval = 10 * time.Second
+
+	// HeartbeatStaleAfter is how old the last heartbeat of a worker gets
+	// before the worker is no longer considered alive: three missed beats.
+	HeartbeatStaleAfter = 3 * HeartbeatInterval
+)
+
+// Heartbeat records the liveness of a worker process and the number of
+// jobs it is running, idle or not, for the operators' queue status.
+type Heartbeat struct {
+	pg      storage.PostgresStore
+	hb      domain.WorkerHeartbeat
+	running func() int
+}
+
+// NewHeartbeat creates the heartbeat of a worker on hostname that runs up
+// to maxJobs jobs; running reports how many it runs now. Each process gets
+// its own ID, so restarts show up as new workers.
+func NewHeartbeat(pg storage.PostgresStore, hostname string, maxJobs int, running func() int) *Heartbeat {
+	return &Heartbeat{
+		pg: pg,
+		hb: domain.WorkerHeartbeat{
+			WorkerID:  uuid.NewString(),
+			Hostname:  hostname,
+			StartedAt: time.Now().UTC(),
+			MaxJobs:   maxJobs,
+		},
+		running: running,
+	}
+}
+
+// WorkerID is the ID the worker's heartbeats are recorded under.
+func (h *Heartbeat) WorkerID() string {
+	return h.hb.WorkerID
+}
+
+// Beat records one heartbeat.
+func (h *Heartbeat) Beat(ctx context.Context) error {
+	hb := h.hb
+	hb.RunningJobs = h.running()
+	if err := h.pg.RecordWorkerHeartbeat(ctx, &hb); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+	return nil
+}
+
+// Stop removes the worker's heartbeat on a clean shutdown, so that it is
+// not reported as a dead worker.
+func (h *Heartbeat) Stop(ctx context.Context) error {
+	if err := h.pg.DeleteWorkerHeartbeat(ctx, h.hb.WorkerID); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+	return nil
+}
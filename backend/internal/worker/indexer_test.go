@@ -100,7 +100,7 @@ func TestIndexEntries_ValidEntries(t *testing.T) {
 			EntryID:    "entry-1",
 			LineNumber: 1,
 			LogType:    domain.LogTypeAPI,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 			ThreadID:   "T001",
 			APICode:    "GET_ENTRY",
 			Form:       "HPD:Help Desk",
@@ -114,7 +114,7 @@ func TestIndexEntries_ValidEntries(t *testing.T) {
 			EntryID:    "entry-2",
 			LineNumber: 2,
 			LogType:    domain.LogTypeSQL,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 			ThreadID:   "T002",
 			SQLTable:   "T12345",
 			DurationMS: 200,
@@ -145,7 +145,7 @@ func TestIndexEntries_MultipleEntriesLargeBatch(t *testing.T) {
 			EntryID:    "entry-" + time.Now().Format("20060102150405") + "-" + string(rune('A'+i%26)),
 			LineNumber: uint32(i + 1),
 			LogType:    domain.LogTypeAPI,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 			ThreadID:   "T001",
 			APICode:    "GET_ENTRY",
 			DurationMS: uint32(100 + i),
@@ -174,7 +174,7 @@ func TestIndexEntries_MultipleTenants(t *testing.T) {
 			EntryID:    "t1-entry-1",
 			LineNumber: 1,
 			LogType:    domain.LogTypeAPI,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 			Success:    true,
 		},
 	}
@@ -189,7 +189,7 @@ func TestIndexEntries_MultipleTenants(t *testing.T) {
 			EntryID:    "t2-entry-1",
 			LineNumber: 1,
 			LogType:    domain.LogTypeSQL,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 			Success:    true,
 		},
 	}
@@ -215,7 +215,7 @@ func TestIndexEntries_CancelledContext(t *testing.T) {
 			EntryID:    "entry-1",
 			LineNumber: 1,
 			LogType:    domain.LogTypeAPI,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 		},
 	}
 
@@ -254,7 +254,7 @@ func TestIndexEntries_AllLogTypes(t *testing.T) {
 					EntryID:    "entry-" + string(lt),
 					LineNumber: 1,
 					LogType:    lt,
-					Timestamp:  time.Now().UTC(),
+					Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 					Success:    true,
 				},
 			}
@@ -283,7 +283,7 @@ func TestIndexEntries_ErrorWrapping(t *testing.T) {
 			EntryID:    "entry-1",
 			LineNumber: 1,
 			LogType:    domain.LogTypeAPI,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 		},
 	}
 	err = idx.IndexEntries(ctx, "tenant-err", entries)
@@ -304,7 +304,7 @@ func TestIndexEntries_ErrorWrapping(t *testing.T) {
 			EntryID:    "entry-2",
 			LineNumber: 2,
 			LogType:    domain.LogTypeAPI,
-			Timestamp:  time.Now().UTC(),
+			Timestamp:  domain.NewTimestamp(time.Now().UTC()),
 		},
 	}
 
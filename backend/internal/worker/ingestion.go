@@ -2,17 +2,25 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/clock"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/logparser"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/usage"
 )
 
 // JARRunner abstracts the JAR execution so tests can substitute a mock.
@@ -20,21 +28,134 @@ type JARRunner interface {
 	Run(ctx context.Context, filePath string, flags domain.JARFlags, heapMB int, lineCallback func(string)) (*jar.Result, error)
 }
 
+// ParserVersion identifies the output of the parse and section computation
+// steps. Bump it whenever the cached analysis sections change for the same
+// input so that HTTP caches holding older payloads are invalidated.
+var ParserVersion = "2"
+
 // Pipeline orchestrates the ingestion flow: download -> JAR -> parse -> store.
 type Pipeline struct {
 	pg      storage.PostgresStore
 	ch      storage.ClickHouseStore
-	s3      storage.S3Storage
+	s3      storage.ObjectStorage
 	redis   storage.RedisCache
 	nats    streaming.NATSStreamer
 	jar     JARRunner
 	anomaly *AnomalyDetector
+
+	// legacy maps log formats the default JAR cannot analyse to a JAR
+	// that can. Formats missing here fail the job before the JAR runs.
+	legacy map[domain.LogFormat]JARRunner
+
+	// thresholds evaluates tenant threshold rules once entries are stored.
+	// Nil disables the step.
+	thresholds *ThresholdEvaluator
+
+	// ingestionFilters applies the tenant's ingestion filter rules to the
+	// entries before they are stored. Off, every entry is stored.
+	ingestionFilters bool
+
+	// skewThreshold is the clock offset between captured files above which
+	// skew is reported. Zero means DefaultClockSkewThreshold.
+	skewThreshold time.Duration
+
+	// restartWarmup is how long after a detected restart entries are left
+	// out of latency factors. Zero means DefaultRestartWarmup; negative
+	// disables the warm-up window.
+	restartWarmup time.Duration
+
+	// parseOpts tunes parsing of the JAR report. Jobs flagged StrictParse
+	// are parsed strictly whatever parseOpts.Strict says.
+	parseOpts jar.ParseOptions
+
+	// outputFormat is the report format asked of the JAR for jobs that do
+	// not choose one. Empty means the text report.
+	outputFormat string
+
+	// storeJAROutput keeps the JAR's text report in object storage under
+	// JAROutputKey for parser fixture capture.
+	storeJAROutput bool
+
+	// checkpoints saves the progress of each job, from which a job
+	// redelivered after a worker crash resumes.
+	checkpoints bool
+
+	// cancelPoll is how often a running job polls for its cancellation.
+	// Zero disables polling.
+	cancelPoll time.Duration
+
+	// rawTextLimit caps the raw text stored per entry, in characters, for
+	// jobs that set no limit of their own. Zero keeps the full text.
+	rawTextLimit int
+
+	// queue republishes the queue estimates of the waiting jobs whenever a
+	// job starts. Nil disables the updates.
+	queue *QueueEstimator
+
+	// usage accounts completed jobs, stored rows and JAR time to the
+	// tenant. Nil disables accounting.
+	usage *usage.Recorder
+
+	// regions resolves the data residency region of the job's tenant,
+	// reloaded as the job starts. Nil without regions.
+	regions storage.RegionResolver
+
+	// events records the stages, milestones, retries and publishes of each
+	// job to its event log. Nil disables the log.
+	events *jobevents.Appender
+
+	// vocabularyMonths and vocabularyMaxValues bound the tenant vocabulary:
+	// values unseen for that many months, and the least recently seen
+	// values of a field beyond the maximum, are evicted. Zero means the
+	// defaults.
+	vocabularyMonths    int
+	vocabularyMaxValues int
+
+	// dataQuality classifies the divergence of the stored entries from the
+	// JAR's counts. Zero values mean the defaults.
+	dataQuality DataQualityThresholds
+
+	// focusMinWidth and focusMaxWidth bound the width of the focus window
+	// computed at completion. Zero means the defaults.
+	focusMinWidth time.Duration
+	focusMaxWidth time.Duration
+
+	// driftAlerts publishes an alert for each job whose capture drifted
+	// from its reference capture.
+	driftAlerts bool
+
+	// settings gives the dashboard cache TTL and the progress cadence at
+	// each job. Nil means the defaults.
+	settings config.Dynamic
+
+	// clock and ids stamp the records each job writes. Nil means the
+	// system clock and random IDs.
+	clock clock.Clock
+	ids   clock.IDGenerator
 }
 
+const (
+	// DefaultVocabularyMonths is how long a value stays in the tenant
+	// vocabulary without being seen again.
+	DefaultVocabularyMonths = 6
+
+	// DefaultVocabularyMaxValues is the number of values of one field kept
+	// in the tenant vocabulary.
+	DefaultVocabularyMaxValues = 50000
+
+	// DefaultDashboardCacheTTL is how long the dashboard and sections of a
+	// job stay cached.
+	DefaultDashboardCacheTTL = 24 * time.Hour
+
+	// DefaultProgressEveryLines is how many lines of JAR output pass
+	// between two progress updates of a job.
+	DefaultProgressEveryLines = 1000
+)
+
 func NewPipeline(
 	pg storage.PostgresStore,
 	ch storage.ClickHouseStore,
-	s3 storage.S3Storage,
+	s3 storage.ObjectStorage,
 	redis storage.RedisCache,
 	nats streaming.NATSStreamer,
 	jarRunner JARRunner,
@@ -43,19 +164,203 @@ func NewPipeline(
 	return &Pipeline{pg: pg, ch: ch, s3: s3, redis: redis, nats: nats, jar: jarRunner, anomaly: anomalyDetector}
 }
 
-// ProcessJob runs the full ingestion pipeline for an analysis job.
-func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error {
+// SetLegacyRunners registers JAR runners for log formats older than the
+// layout the default JAR expects.
+func (p *Pipeline) SetLegacyRunners(runners map[domain.LogFormat]JARRunner) {
+	p.legacy = runners
+}
+
+// SetThresholdEvaluator enables threshold rule evaluation after ingestion.
+func (p *Pipeline) SetThresholdEvaluator(e *ThresholdEvaluator) {
+	p.thresholds = e
+}
+
+// SetIngestionFilters enables the tenant's ingestion filter rules.
+func (p *Pipeline) SetIngestionFilters(enabled bool) {
+	p.ingestionFilters = enabled
+}
+
+// SetClockSkewThreshold sets the offset between captured files above which
+// clock skew is reported.
+func (p *Pipeline) SetClockSkewThreshold(d time.Duration) {
+	p.skewThreshold = d
+}
+
+// SetRestartWarmup sets how long after a detected server restart entries
+// are left out of latency factors.
+func (p *Pipeline) SetRestartWarmup(d time.Duration) {
+	p.restartWarmup = d
+}
+
+// SetParseOptions sets the options the JAR report is parsed with.
+func (p *Pipeline) SetParseOptions(opts jar.ParseOptions) {
+	p.parseOpts = opts
+}
+
+// SetOutputFormat sets the report format asked of the JAR for jobs that do
+// not choose one: domain.JAROutputXML, domain.JAROutputJSON or empty for text.
+func (p *Pipeline) SetOutputFormat(format string) {
+	p.outputFormat = format
+}
+
+// SetVocabularyLimits bounds the tenant vocabulary to values seen in the
+// last months and to maxValues values per field.
+func (p *Pipeline) SetVocabularyLimits(months, maxValues int) {
+	p.vocabularyMonths = months
+	p.vocabularyMaxValues = maxValues
+}
+
+// jobParseOptions returns the options the JAR report of job is parsed with.
+func (p *Pipeline) jobParseOptions(job domain.AnalysisJob) jar.ParseOptions {
+	opts := p.parseOpts
+	if job.JARFlags.StrictParse {
+		opts.Strict = true
+	}
+	return opts
+}
+
+// jobOutputFormat returns the structured report format job is analysed
+// with, or empty for the text report.
+func (p *Pipeline) jobOutputFormat(job domain.AnalysisJob) string {
+	format := job.JARFlags.OutputFormat
+	if format == "" {
+		format = p.outputFormat
+	}
+	switch format {
+	case domain.JAROutputXML, domain.JAROutputJSON:
+		return format
+	}
+	return ""
+}
+
+// SetQueueEstimator enables queue position updates of waiting jobs.
+func (p *Pipeline) SetQueueEstimator(e *QueueEstimator) {
+	p.queue = e
+}
+
+// SetUsageRecorder enables usage accounting of processed jobs.
+func (p *Pipeline) SetUsageRecorder(r *usage.Recorder) {
+	p.usage = r
+}
+
+// SetRegions makes every job reload its tenant's region before touching
+// its data, so that a job never runs on the storage of a region the tenant
+// has left.
+func (p *Pipeline) SetRegions(r storage.RegionResolver) {
+	p.regions = r
+}
+
+// SetDynamic makes the pipeline read the dashboard cache TTL and the
+// progress cadence from settings at each job.
+func (p *Pipeline) SetDynamic(settings config.Dynamic) {
+	p.settings = settings
+}
+
+// SetClock makes the pipeline stamp, and poll for cancellation, by c and
+// make record IDs with ids, so that tests can predict both.
+func (p *Pipeline) SetClock(c clock.Clock, ids clock.IDGenerator) {
+	p.clock = c
+	p.ids = ids
+}
+
+// clockOrReal returns the clock of the pipeline.
+func (p *Pipeline) clockOrReal() clock.Clock {
+	if p.clock == nil {
+		return clock.Real
+	}
+	return p.clock
+}
+
+// now returns the time of the pipeline's clock in UTC.
+func (p *Pipeline) now() time.Time {
+	return p.clockOrReal().Now().UTC()
+}
+
+// newID returns a new record ID.
+func (p *Pipeline) newID() uuid.UUID {
+	if p.ids == nil {
+		return clock.RandomIDs.New()
+	}
+	return p.ids.New()
+}
+
+// Configure applies the options of cfg shared by the pipelines of the worker
+// and of the API's inline analyses. The JAR runners, the collaborators and
+// the regions are set by the caller.
+func (p *Pipeline) Configure(cfg *config.Config) {
+	p.SetIngestionFilters(true)
+	p.SetClockSkewThreshold(time.Duration(cfg.ClockSkewThresholdMS) * time.Millisecond)
+	p.SetRestartWarmup(time.Duration(cfg.RestartWarmupSec) * time.Second)
+	p.SetDataQualityThresholds(DataQualityThresholds{MinorPct: cfg.DataQualityMinorPct, MajorPct: cfg.DataQualityMajorPct})
+	p.SetFocusWindowWidth(time.Duration(cfg.FocusWindowMinMinutes)*time.Minute, time.Duration(cfg.FocusWindowMaxMinutes)*time.Minute)
+	p.SetParseOptions(jar.ParseOptions{MaxSectionRows: cfg.JARMaxSectionRows, Strict: cfg.JARStrictParse})
+	p.SetOutputFormat(cfg.JAROutputFormat)
+	p.SetStoreJAROutput(cfg.JARStoreOutput)
+	p.SetRawTextLimit(cfg.RawTextLimit)
+	p.SetVocabularyLimits(cfg.VocabularyMonths, cfg.VocabularyMaxValues)
+	p.SetCaptureDriftAlerts(cfg.CaptureDriftAlerts)
+	p.SetCancellationPoll(time.Duration(cfg.JobCancelPollMS) * time.Millisecond)
+}
+
+// LegacyRunners returns a runner made by newRunner for each legacy JAR of
+// paths, keyed by version families such as "9.x" or "20.x", by the log
+// format it analyses.
+func LegacyRunners(paths map[string]string, newRunner func(path string) JARRunner) map[domain.LogFormat]JARRunner {
+	runners := make(map[domain.LogFormat]JARRunner, len(paths))
+	for version, path := range paths {
+		format := domain.LogFormat("ar-" + strings.TrimPrefix(version, "ar-"))
+		runners[format] = newRunner(path)
+		slog.Info("legacy JAR registered", "format", format, "jar_path", path)
+	}
+	return runners
+}
+
+func (p *Pipeline) dashboardCacheTTL() time.Duration {
+	if p.settings != nil {
+		return p.settings.DashboardCacheTTL()
+	}
+	return DefaultDashboardCacheTTL
+}
+
+func (p *Pipeline) progressEveryLines() int64 {
+	if p.settings != nil {
+		return int64(max(p.settings.ProgressEveryLines(), 1))
+	}
+	return DefaultProgressEveryLines
+}
+
+// processJob runs the ingestion pipeline of ProcessJob.
+func (p *Pipeline) processJob(ctx context.Context, job domain.AnalysisJob) error {
 	tenantID := job.TenantID.String()
 	jobID := job.ID.String()
 	logger := slog.With("job_id", jobID, "tenant_id", tenantID)
+	// The pipeline reads back what it just wrote: none of its reads may go
+	// to a replica that has not caught up yet.
+	ctx = storage.WithPrimary(storage.WithTenant(ctx, tenantID))
+	if p.regions != nil {
+		if _, err := p.regions.RefreshTenant(ctx, tenantID); err != nil {
+			return p.failJob(ctx, job, "resolve storage region: "+err.Error())
+		}
+	}
 
-	// 1. Update status to parsing.
+	// 1. Update status to parsing. A job cancelled while it was queued
+	// stops here.
 	if err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusParsing, nil); err != nil {
+		if errors.Is(err, storage.ErrJobCancelled) {
+			return p.skipCancelled(ctx, job, logger)
+		}
+		p.recordEvent(job, domain.JobEventWarning, "", "status update to parsing failed", map[string]any{"error": err.Error()})
 		return fmt.Errorf("update status to parsing: %w", err)
 	}
-	_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 5, string(domain.JobStatusParsing), "downloading file")
+	p.publishProgress(ctx, job, 5, domain.JobStatusParsing, "downloading file")
+	if p.queue != nil {
+		if err := p.queue.PublishQueued(ctx, p.nats); err != nil {
+			logger.Warn("failed to update queue estimates", "error", err)
+		}
+	}
 
 	// 2. Get file metadata.
+	finishDownload := p.startStage(job, "download")
 	file, err := p.pg.GetLogFile(ctx, job.TenantID, job.FileID)
 	if err != nil {
 		return p.failJob(ctx, job, "file not found: "+err.Error())
@@ -74,46 +379,73 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 	}
 	defer os.Remove(tmpFile.Name())
 
-	if _, err := io.Copy(tmpFile, reader); err != nil {
+	written, err := io.Copy(tmpFile, reader)
+	if err != nil {
 		tmpFile.Close()
 		return p.failJob(ctx, job, "download to temp: "+err.Error())
 	}
 	if err := tmpFile.Close(); err != nil {
 		return p.failJob(ctx, job, "close temp file: "+err.Error())
 	}
+	if inlineTooLarge(ctx, written) {
+		return p.failJob(ctx, job, "file larger than expected")
+	}
+	finishDownload(map[string]any{"size_bytes": file.SizeBytes})
+	if err := jobCancelled(ctx); err != nil {
+		return err
+	}
 
-	_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 15, string(domain.JobStatusParsing), "running JAR analysis")
-	logger.Info("file downloaded, starting JAR", "path", tmpFile.Name(), "size", file.SizeBytes)
+	// 3a0. Logs of other sources than the AR Server skip the JAR.
+	if file.SourceType != "" && file.SourceType != domain.LogSourceARServer {
+		return p.processSourceJob(ctx, job, file, tmpFile.Name(), logger)
+	}
 
-	// 4. Run JAR.
-	lineCount := int64(0)
-	callback := func(line string) {
-		lineCount++
-		if lineCount%1000 == 0 {
-			pct := 15 + int(float64(lineCount)/float64(max(file.SizeBytes/100, 1)))
-			if pct > 70 {
-				pct = 70
-			}
-			_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, pct, string(domain.JobStatusParsing), fmt.Sprintf("processed %d lines", lineCount))
+	// 3a. Check the file and run the JAR, unless an interrupted run of the
+	// job kept its report: the file was checked before that run's JAR.
+	checkpoint := p.loadCheckpoint(ctx, job, logger)
+	result, outputFormat, reused := checkpoint.jarOutput(ctx)
+	if reused {
+		logger.Info("reusing the JAR output of the interrupted run", "s3_key", checkpoint.cp.JAROutputKey)
+	} else {
+		result, outputFormat, err = p.runJAR(ctx, &job, file, tmpFile.Name(), logger)
+		if err != nil {
+			return err
 		}
+		checkpoint.jarDone(ctx, result.Stdout, outputFormat)
+	}
+	if err := jobCancelled(ctx); err != nil {
+		return err
 	}
 
-	result, err := p.jar.Run(ctx, tmpFile.Name(), job.JARFlags, job.JVMHeapMB, callback)
-	if err != nil {
-		stderr := ""
-		if result != nil {
-			stderr = result.Stderr
-		}
-		return p.failJob(ctx, job, fmt.Sprintf("JAR execution failed: %s (stderr: %s)", err.Error(), stderr))
+	// 4b. Keep the text report for parser fixture capture.
+	if p.storeJAROutput && !reused && !jar.IsStructuredOutput(result.Stdout, outputFormat) {
+		p.storeJAROutputReport(ctx, job, result.Stdout, logger)
 	}
 
-	_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 75, string(domain.JobStatusAnalyzing), "parsing JAR output")
+	p.publishProgress(ctx, job, 75, domain.JobStatusAnalyzing, "parsing JAR output")
 
 	// 5. Parse JAR output.
-	parseResult, err := jar.ParseOutput(result.Stdout)
+	finishParse := p.startStage(job, "parse")
+	var parseResult *domain.ParseResult
+	if outputFormat != "" && jar.IsStructuredOutput(result.Stdout, outputFormat) {
+		parseResult, err = jar.ParseStructuredOutput(strings.NewReader(result.Stdout), outputFormat)
+	} else {
+		parseResult, err = jar.ParseOutputWithOptions(result.Stdout, p.jobParseOptions(job))
+	}
 	if err != nil {
 		return p.failJob(ctx, job, "parse output: "+err.Error())
 	}
+	// 5a. Redact the SQL literals of the report before any section is
+	// derived from it and cached.
+	redactor, err := p.loadSQLRedactor(ctx, job.TenantID)
+	if err != nil {
+		return p.failJob(ctx, job, err.Error())
+	}
+	redactor.parseResult(parseResult)
+	for _, warning := range parseResult.Warnings {
+		p.recordEvent(job, domain.JobEventWarning, "parse", warning,
+			map[string]any{"jar_version": parseResult.JARVersion, "profile": parseResult.Profile})
+	}
 	dashboard := parseResult.Dashboard
 
 	// 5b. Enhance parse result: fill in computed sections where JAR-native data is absent.
@@ -139,6 +471,32 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 
 	// 5b3. Generate distribution maps from aggregates and TopN data.
 	dashboard.Distribution = generateDistribution(dashboard, parseResult)
+	if parseResult.Sections != nil {
+		dashboard.NoData = dashboardNoData(parseResult.Sections)
+	}
+
+	// 5b4. Name API calls from the abbreviation legend and keep the legend
+	// for the search, trace and legend endpoints.
+	legend := jar.NewAPILegend(parseResult.APIAbbreviations)
+	legend.ExpandTopN(dashboard.TopAPICalls)
+	legend.ExpandTopN(parseResult.QueuedAPICalls)
+	legend.ExpandQueued(parseResult.JARQueuedAPICalls)
+	legend.ExpandAggregates(parseResult.JARAggregates)
+	if len(parseResult.APIAbbreviations) > 0 {
+		if err := p.pg.UpdateJobAPILegend(ctx, job.TenantID, job.ID, parseResult.APIAbbreviations); err != nil {
+			logger.Warn("failed to store api legend", "error", err)
+		}
+	}
+
+	// 5b5. Keep the threads configured per queue, the capacity the thread
+	// statistics are measured against.
+	if counts := dashboard.GeneralStats.ThreadCounts; len(counts) > 0 {
+		if err := p.pg.UpdateJobThreadCounts(ctx, job.TenantID, job.ID, counts); err != nil {
+			logger.Warn("failed to store thread counts", "error", err)
+		} else {
+			job.ThreadCounts = counts
+		}
+	}
 
 	// 5c. Run anomaly detection on parsed dashboard data.
 	var anomalies []Anomaly
@@ -152,71 +510,68 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 	}
 
 	// 5d. Cache dashboard and section data in Redis.
+	var cachedSections []string
 	if p.redis != nil {
-		sectionTTL := 24 * time.Hour
+		sectionTTL := p.dashboardCacheTTL()
 		cachePrefix := p.redis.TenantKey(tenantID, "dashboard", jobID)
 
-		// Cache the full dashboard data (used by GET /analysis/{job_id}/dashboard).
-		if err := p.redis.Set(ctx, cachePrefix, dashboard, sectionTTL); err != nil {
-			logger.Warn("redis cache set failed", "section", "dashboard", "error", err)
+		cacheSection := func(section string, value any) {
+			key := cachePrefix
+			if section != "dashboard" {
+				key += ":" + section
+			}
+			if err := p.redis.Set(ctx, key, value, sectionTTL); err != nil {
+				logger.Warn("redis cache set failed", "section", section, "error", err)
+				return
+			}
+			cachedSections = append(cachedSections, section)
 		}
 
+		// Cache the full dashboard data (used by GET /analysis/{job_id}/dashboard).
+		cacheSection("dashboard", dashboard)
+
 		// Cache individual sections for lazy-loaded dashboard endpoints.
 		// Prefer JAR-native data over computed data when available.
 
 		// Aggregates: JAR-native (6 grouping dimensions) or computed fallback.
-		if parseResult.JARAggregates != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":agg", parseResult.JARAggregates, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "agg", "error", err)
-			}
+		if !sectionPresent(parseResult.Sections, "agg") {
+			logger.Debug("skipping cache of empty section", "section", "agg")
+		} else if parseResult.JARAggregates != nil {
+			cacheSection("agg", parseResult.JARAggregates)
 		} else if parseResult.Aggregates != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":agg", parseResult.Aggregates, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "agg", "error", err)
-			}
+			cacheSection("agg", parseResult.Aggregates)
 		}
 
 		// Exceptions: JAR-native (API errors + exceptions) or computed fallback.
-		if parseResult.JARExceptions != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":exc", parseResult.JARExceptions, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "exc", "error", err)
-			}
+		if !sectionPresent(parseResult.Sections, "exc") {
+			logger.Debug("skipping cache of empty section", "section", "exc")
+		} else if parseResult.JARExceptions != nil {
+			cacheSection("exc", parseResult.JARExceptions)
 		} else if parseResult.Exceptions != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":exc", parseResult.Exceptions, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "exc", "error", err)
-			}
+			cacheSection("exc", parseResult.Exceptions)
 		}
 
 		// Gaps: JAR-native (line + thread gaps) or computed fallback.
 		if parseResult.JARGaps != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":gaps", parseResult.JARGaps, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "gaps", "error", err)
-			}
+			cacheSection("gaps", parseResult.JARGaps)
 		} else if parseResult.Gaps != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":gaps", parseResult.Gaps, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "gaps", "error", err)
-			}
+			cacheSection("gaps", parseResult.Gaps)
 		}
 
 		// Thread stats: JAR-native (per-queue, with busy%) or computed fallback.
 		if parseResult.JARThreadStats != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":threads", parseResult.JARThreadStats, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "threads", "error", err)
-			}
+			cacheSection("threads", parseResult.JARThreadStats)
 		} else if parseResult.ThreadStats != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":threads", parseResult.ThreadStats, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "threads", "error", err)
-			}
+			cacheSection("threads", parseResult.ThreadStats)
 		}
 
 		// Filters: JAR-native (5 sub-sections) or computed fallback.
-		if parseResult.JARFilters != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":filters", parseResult.JARFilters, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "filters", "error", err)
-			}
+		if !sectionPresent(parseResult.Sections, "filters") {
+			logger.Debug("skipping cache of empty section", "section", "filters")
+		} else if parseResult.JARFilters != nil {
+			cacheSection("filters", parseResult.JARFilters)
 		} else if parseResult.Filters != nil {
-			if err := p.redis.Set(ctx, cachePrefix+":filters", parseResult.Filters, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "filters", "error", err)
-			}
+			cacheSection("filters", parseResult.Filters)
 		}
 
 		// Queued API calls: supplementary data from JAR output.
@@ -224,11 +579,11 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 			resp := domain.QueuedCallsResponse{
 				JobID:          jobID,
 				QueuedAPICalls: parseResult.QueuedAPICalls,
+				Calls:          parseResult.JARQueuedAPICalls,
+				Sort:           parseResult.QueuedAPICallsSort,
 				Total:          len(parseResult.QueuedAPICalls),
 			}
-			if err := p.redis.Set(ctx, cachePrefix+":queued", resp, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "queued", "error", err)
-			}
+			cacheSection("queued", resp)
 		}
 
 		// Logging activities: parsed from JAR output.
@@ -237,9 +592,7 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 				JobID:      jobID,
 				Activities: parseResult.LoggingActivities,
 			}
-			if err := p.redis.Set(ctx, cachePrefix+":logging-activity", resp, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "logging-activity", "error", err)
-			}
+			cacheSection("logging-activity", resp)
 		}
 
 		// File metadata: parsed from JAR output.
@@ -249,42 +602,262 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 				Files: parseResult.FileMetadataList,
 				Total: len(parseResult.FileMetadataList),
 			}
-			if err := p.redis.Set(ctx, cachePrefix+":file-metadata", resp, sectionTTL); err != nil {
-				logger.Warn("redis cache set failed", "section", "file-metadata", "error", err)
-			}
+			cacheSection("file-metadata", resp)
 		}
 	}
 
-	// 6. Update status to storing.
+	finishParse(map[string]any{"anomalies": len(anomalies)})
+	if err := jobCancelled(ctx); err != nil {
+		return err
+	}
+	checkpoint.parsed(ctx, cachedSections)
+
+	// 6. Update status to storing. The update is refused once the
+	// cancellation of the job was requested.
 	if err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusStoring, nil); err != nil {
+		if errors.Is(err, storage.ErrJobCancelled) {
+			return ErrJobCancelled
+		}
 		logger.Error("failed to update status to storing", "error", err)
+		p.recordEvent(job, domain.JobEventWarning, "", "status update to storing failed", map[string]any{"error": err.Error()})
 	}
-	_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 85, string(domain.JobStatusStoring), "storing results")
+	p.publishProgress(ctx, job, 85, domain.JobStatusStoring, "storing results")
+	finishIngest := p.startStage(job, "ingest")
 
 	// 7. Parse raw log file and store individual entries in ClickHouse.
-	count, parseErr := logparser.ParseFile(ctx, tmpFile.Name(), tenantID, jobID, 5000, func(batch []domain.LogEntry) error {
-		return p.ch.BatchInsertEntries(ctx, batch)
+	// Multi-file captures are first checked for clock skew between files.
+	files := parseResult.FileMetadataList
+	var offsets map[uint16]time.Duration
+	skewedFiles := 0
+	if skew := p.detectClockSkew(ctx, tmpFile.Name(), tenantID, jobID, files); skew != nil {
+		if job.CorrectClockSkew {
+			offsets = skewOffsets(skew)
+		}
+		skewedFiles = len(skew.Files)
+		logger.Warn("clock skew detected between captured files",
+			"skewed_files", skewedFiles,
+			"threshold_ms", skew.ThresholdMS,
+			"corrected", offsets != nil,
+		)
+		p.recordEvent(job, domain.JobEventWarning, "ingest", "clock skew detected between captured files",
+			map[string]any{"skewed_files": skewedFiles, "corrected": offsets != nil})
+		if err := p.pg.UpdateJobClockSkew(ctx, job.TenantID, job.ID, skew); err != nil {
+			logger.Warn("failed to record clock skew", "error", err)
+		}
+		job.ClockSkew = skew
+	}
+
+	retention := p.tenantRetentionClass(ctx, job.TenantID)
+	clients := newClientStamper(parseResult.JARAggregates)
+	restarts := newRestartCollector()
+	filter := p.loadIngestionFilter(ctx, job.TenantID)
+	sampler := newSampler(job.Sampling)
+	trimmer := newRawTextTrimmer(p.jobRawTextLimit(job), parseResult)
+	ingested := make(map[domain.LogType]int64)
+	var clamped int64
+	count, parseErr := logparser.ParseCapture(ctx, tmpFile.Name(), tenantID, jobID, files, 5000, func(batch []domain.LogEntry) error {
+		if err := jobCancelled(ctx); err != nil {
+			return err
+		}
+		for i := range batch {
+			if batch[i].DurationClamped {
+				clamped++
+			}
+		}
+		applySkewCorrection(batch, offsets)
+		stampRetentionClass(batch, retention)
+		clients.stamp(batch)
+		restarts.add(batch)
+		batch = filter.Apply(batch)
+		if sampler != nil {
+			batch = sampler.apply(batch)
+		}
+		redactor.apply(batch)
+		if trimmer != nil {
+			trimmer.apply(batch)
+		}
+		if err := p.insertBatch(ctx, checkpoint, batch); err != nil {
+			return err
+		}
+		for i := range batch {
+			ingested[batch[i].LogType] += int64(max(batch[i].SampleWeight, 1))
+		}
+		return nil
 	})
+	if err := jobCancelled(ctx); err != nil {
+		return err
+	}
+	dropped, flagged := filter.Totals()
 	if parseErr != nil {
 		logger.Error("log entry ingestion failed (non-fatal)", "error", parseErr, "entries_parsed", count)
+		p.recordEvent(job, domain.JobEventWarning, "ingest", "log entry ingestion failed",
+			map[string]any{"error": parseErr.Error(), "entries_parsed": count})
 	} else {
-		logger.Info("log entry ingestion complete", "entries_inserted", count)
+		logger.Info("log entry ingestion complete", "entries_inserted", count-dropped, "files", max(len(files), 1), "skewed_files", skewedFiles)
 	}
-	_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 95, string(domain.JobStatusStoring), "log entries indexed")
 
-	// 8. Update job with completion stats.
-	now := time.Now().UTC()
+	// Keep what the ingestion filter rules matched, so that filtered entries
+	// are accounted for rather than silently missing.
+	if !filter.Empty() {
+		logger.Info("ingestion filter rules applied", "entries_dropped", dropped, "entries_flagged", flagged)
+		stats := filter.Stats()
+		if err := p.pg.UpdateJobIngestionFilterStats(ctx, job.TenantID, job.ID, stats); err != nil {
+			logger.Warn("failed to record ingestion filter stats", "error", err)
+		} else {
+			job.IngestionFilters = stats
+		}
+	}
+
+	// A sampled capture then has its hot windows ingested in full. The
+	// second pass repeats what the first did before sampling, with its own
+	// client stamper and filter counts.
+	if sampler != nil && parseErr == nil {
+		secondClients := newClientStamper(parseResult.JARAggregates)
+		secondFilter := filter.fresh()
+		p.finishSampling(ctx, &job, sampler, checkpoint, dashboard, anomalies, tmpFile.Name(), files, func(batch []domain.LogEntry) []domain.LogEntry {
+			applySkewCorrection(batch, offsets)
+			stampRetentionClass(batch, retention)
+			secondClients.stamp(batch)
+			batch = secondFilter.Apply(batch)
+			redactor.apply(batch)
+			if trimmer != nil {
+				trimmer.apply(batch)
+			}
+			return batch
+		})
+	}
+	if clamped > 0 {
+		logger.Warn("negative durations clamped to zero", "entries_clamped", clamped)
+		p.recordEvent(job, domain.JobEventWarning, "ingest", "negative durations clamped to zero",
+			map[string]any{"entries_clamped": clamped})
+	}
+	if redactor != nil && redactor.redacted > 0 {
+		logger.Info("sql literals redacted", "entries_redacted", redactor.redacted)
+	}
+	if trimmer != nil && trimmer.truncated > 0 {
+		logger.Info("raw text truncated", "entries_truncated", trimmer.truncated, "limit", trimmer.limit)
+	}
+	if checkpoint != nil && checkpoint.skipped > 0 {
+		logger.Info("batches inserted by the interrupted run skipped", "batches_skipped", checkpoint.skipped)
+	}
+	if parseErr == nil {
+		checkpoint.ingested(ctx)
+	}
+
+	// 7a0. Record the server restarts within the capture.
+	if parseErr == nil {
+		p.recordRestarts(ctx, &job, restarts, files)
+	}
+
+	// 7a. Record which log types the capture holds. The ingested counts
+	// decide when ingestion completed; otherwise the JAR's view stands.
+	if sections := parseResult.Sections; sections != nil {
+		if parseErr == nil {
+			reconcileSectionPresence(sections, ingested)
+			for _, w := range sections.Warnings {
+				logger.Warn("section count discrepancy", "warning", w)
+			}
+		}
+		if err := p.pg.UpdateJobSectionPresence(ctx, job.TenantID, job.ID, sections); err != nil {
+			logger.Warn("failed to record section presence", "error", err)
+		}
+		job.Sections = sections
+	}
+
+	// 7a1. Keep the diagnostics of a strict parse with the ingestion stats.
+	if diag := parseResult.Diagnostics; diag != nil {
+		logger.Info("jar report parse diagnostics",
+			"sections_seen", diag.SectionsSeen,
+			"sections_recognized", diag.SectionsRecognized,
+			"skipped_sections", diag.SkippedSections,
+			"row_errors", len(diag.RowErrors)+diag.RowErrorsDropped,
+			"rows_present", diag.RowsPresent,
+			"rows_parsed", diag.RowsParsed,
+			"entries_inserted", count-dropped,
+		)
+		if err := p.pg.UpdateJobParseDiagnostics(ctx, job.TenantID, job.ID, diag); err != nil {
+			logger.Warn("failed to record parse diagnostics", "error", err)
+		}
+	}
+	// 7a2. Check the stored entries against the JAR's counts, net of those
+	// the ingestion filter rules dropped, and count the durations clamped.
+	p.reconcileIngestion(ctx, &job, dashboard.GeneralStats, filter.DroppedByType(), clamped)
+
+	// 7a3. Roll the stored entries up by minute for the analytics read
+	// next. Without the rollup they are read from the entries themselves.
+	if count > 0 {
+		if err := p.ch.BuildJobRollup(ctx, job.TenantID.String(), job.ID.String()); err != nil {
+			logger.Warn("minute rollup failed (non-fatal)", "error", err)
+			p.recordEvent(job, domain.JobEventWarning, "ingest", "minute rollup failed", map[string]any{"error": err.Error()})
+		}
+	}
+	finishIngest(map[string]any{"entries_parsed": count, "entries_dropped": dropped})
+	p.publishProgress(ctx, job, 95, domain.JobStatusStoring, "log entries indexed")
+
+	// 7b. Evaluate tenant threshold rules against the stored entries.
+	finishPostProcess := p.startStage(job, "post_process")
+	if p.thresholds != nil {
+		violations, err := p.thresholds.Evaluate(ctx, job)
+		if err != nil {
+			logger.Warn("threshold evaluation failed (non-fatal)", "error", err)
+			p.recordEvent(job, domain.JobEventWarning, "post_process", "threshold evaluation failed", map[string]any{"error": err.Error()})
+		} else if violations != nil {
+			n := len(violations)
+			job.ViolationCount = &n
+			logger.Info("threshold rules evaluated", "violations", n)
+		}
+	}
+
+	// 7c. Record when the capture started to fail.
+	if parseErr == nil && count > 0 {
+		p.recordErrorOnset(ctx, &job)
+	}
+
+	// 7c1. Pick the stretch of the capture the dashboard opens on.
+	if parseErr == nil && count > 0 {
+		p.recordFocusWindow(ctx, &job, dashboard.GeneralStats)
+	}
+
+	// 7c2. Check the capture was taken like the tenant's reference capture.
+	if parseErr == nil && count > 0 {
+		p.recordCaptureDrift(ctx, &job, dashboard.GeneralStats, len(files))
+	}
+
+	// 7d. Add the names the capture holds to the tenant vocabulary, then
+	// recover the full names the JAR truncated from the stored ones.
+	if parseErr == nil && count > 0 {
+		vocabulary := p.recordVocabulary(ctx, job)
+		p.resolveTruncatedNames(ctx, job, parseResult, vocabulary)
+	}
+	finishPostProcess(nil)
+	if err := jobCancelled(ctx); err != nil {
+		return err
+	}
+
+	// 8. Update job with completion stats. A cancellation requested before
+	// the update wins over it: the job is then cancelled instead.
+	now := p.now()
 	if err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusComplete, nil); err != nil {
+		if abort := inlineAborted(ctx); abort != nil {
+			return abort
+		}
+		if errors.Is(err, storage.ErrJobCancelled) {
+			return ErrJobCancelled
+		}
 		// Failed to mark as complete - mark as failed to prevent inconsistent state
 		errMsg := fmt.Sprintf("failed to update job to complete status: %v", err)
 		_ = p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusFailed, &errMsg)
-		_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 0, string(domain.JobStatusFailed), errMsg)
+		p.publishProgress(ctx, job, 0, domain.JobStatusFailed, errMsg)
+		p.recordEvent(job, domain.JobEventFailed, "", errMsg, nil)
 		return fmt.Errorf("update status to complete: %w", err)
 	}
 	if err := p.pg.UpdateJobProgress(ctx, job.TenantID, job.ID, 100, &dashboard.GeneralStats.TotalLines); err != nil {
 		logger.Error("failed to update job progress to 100%%", "error", err)
 		// Non-fatal: job status is already Complete, only progress percentage failed
 	}
+	if checkpoint != nil {
+		p.clearCheckpoint(ctx, job, logger)
+	}
 
 	// 8b. Log anomaly detection results (no progress update since job is already complete).
 	if len(anomalies) > 0 {
@@ -301,8 +874,16 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 	job.EscCount = &dashboard.GeneralStats.EscCount
 	job.LogDuration = &dashboard.GeneralStats.LogDuration
 
-	_ = p.nats.PublishJobComplete(ctx, tenantID, jobID, job)
-	_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, 100, string(domain.JobStatusComplete), "analysis complete")
+	p.usage.Record(domain.UsageEvent{TenantID: job.TenantID, Metric: domain.UsageAnalysesRun, SourceID: job.ID, Amount: 1, OccurredAt: now})
+	var rowsStored int64
+	for _, n := range ingested {
+		rowsStored += n
+	}
+	p.usage.Record(domain.UsageEvent{TenantID: job.TenantID, Metric: domain.UsageRowsStored, SourceID: job.ID, Amount: rowsStored, OccurredAt: now})
+
+	p.publishComplete(ctx, job)
+	p.publishProgress(ctx, job, 100, domain.JobStatusComplete, "analysis complete")
+	p.recordEvent(job, domain.JobEventCompleted, "", "", map[string]any{"rows_stored": rowsStored})
 
 	logger.Info("job completed",
 		"total_lines", dashboard.GeneralStats.TotalLines,
@@ -317,6 +898,80 @@ func (p *Pipeline) ProcessJob(ctx context.Context, job domain.AnalysisJob) error
 	return nil
 }
 
+// runJAR checks the downloaded file, picks the JAR for its log format and
+// runs it. It returns the JAR's result and the format of its report, empty
+// for the text report; on error the job has been failed.
+func (p *Pipeline) runJAR(ctx context.Context, job *domain.AnalysisJob, file *domain.LogFile, path string, logger *slog.Logger) (*jar.Result, string, error) {
+	tenantID := job.TenantID.String()
+	jobID := job.ID.String()
+
+	finishPreflight := p.startStage(*job, "preflight")
+
+	// 3a. Check the file is an intact text log before analysing it.
+	if integrity := p.checkIntegrity(ctx, job, path); integrity != nil {
+		if fatal := integrity.Fatal(); fatal != nil {
+			job.ErrorCode = &fatal.Code
+			return nil, "", p.failJob(ctx, *job, "corrupt log file: "+fatal.Message)
+		}
+	}
+
+	// 3b. Detect the log format and pick a JAR that understands it.
+	runner, err := p.selectRunner(ctx, *job, path)
+	if err != nil {
+		return nil, "", p.failJob(ctx, *job, err.Error())
+	}
+	finishPreflight(nil)
+
+	p.publishProgress(ctx, *job, 15, domain.JobStatusParsing, "running JAR analysis")
+	logger.Info("file downloaded, starting JAR", "path", path, "size", file.SizeBytes)
+
+	// 4. Run JAR.
+	lineCount := int64(0)
+	progressEvery := p.progressEveryLines()
+	callback := func(line string) {
+		lineCount++
+		if lineCount%progressEvery == 0 {
+			pct := 15 + int(float64(lineCount)/float64(max(file.SizeBytes/100, 1)))
+			if pct > 70 {
+				pct = 70
+			}
+			_ = p.nats.PublishJobProgress(ctx, tenantID, jobID, pct, string(domain.JobStatusParsing), fmt.Sprintf("processed %d lines", lineCount))
+		}
+	}
+
+	finishJAR := p.startStage(*job, "jar")
+	attempts := 1
+	flags := job.JARFlags
+	flags.OutputFormat = p.jobOutputFormat(*job)
+	result, err := runner.Run(ctx, path, flags, job.JVMHeapMB, callback)
+	if result != nil {
+		p.recordResources(ctx, *job, result)
+	}
+	if err != nil && flags.OutputFormat != "" && jar.UnsupportedOutputFormat(result) {
+		// Older JARs reject -of; their text report carries the same data.
+		logger.Warn("JAR does not support structured output, retrying with text report",
+			"format", flags.OutputFormat, "stderr", result.Stderr)
+		p.recordEvent(*job, domain.JobEventRetry, "jar", "JAR does not support structured output, retrying with text report",
+			map[string]any{"format": flags.OutputFormat})
+		flags.OutputFormat = ""
+		lineCount = 0
+		attempts++
+		result, err = runner.Run(ctx, path, flags, job.JVMHeapMB, callback)
+		if result != nil {
+			p.recordResources(ctx, *job, result)
+		}
+	}
+	if err != nil {
+		stderr := ""
+		if result != nil {
+			stderr = result.Stderr
+		}
+		return nil, "", p.failJob(ctx, *job, fmt.Sprintf("JAR execution failed: %s (stderr: %s)", err.Error(), stderr))
+	}
+	finishJAR(map[string]any{"attempts": attempts, "lines": lineCount})
+	return result, flags.OutputFormat, nil
+}
+
 // detectAnomalies runs the anomaly detector on dashboard top-N data and returns
 // all detected anomalies. The results are collected into a single slice for
 // downstream persistence and notification.
@@ -359,15 +1014,269 @@ func (p *Pipeline) detectAnomalies(ctx context.Context, jobID, tenantID string,
 	return allAnomalies
 }
 
+// detectClockSkew reads a multi-file capture once to pair up traces logged
+// in more than one file and returns the files whose clock is off by more
+// than the skew threshold. Single-file captures and failures return nil.
+func (p *Pipeline) detectClockSkew(ctx context.Context, path, tenantID, jobID string, files []domain.FileMetadata) *domain.ClockSkewReport {
+	if len(files) < 2 {
+		return nil
+	}
+	collector := newSkewCollector()
+	if _, err := logparser.ParseCapture(ctx, path, tenantID, jobID, files, 5000, func(batch []domain.LogEntry) error {
+		collector.add(batch)
+		return nil
+	}); err != nil {
+		slog.Warn("clock skew detection failed (non-fatal)", "job_id", jobID, "error", err)
+		return nil
+	}
+
+	threshold := p.skewThreshold
+	if threshold <= 0 {
+		threshold = DefaultClockSkewThreshold
+	}
+	return clockSkewReport(collector, files, threshold)
+}
+
+// recordRestarts detects the server restarts within the capture from the
+// entries sampled while it was ingested and records them with the job.
+// Failures are logged and otherwise ignored.
+func (p *Pipeline) recordRestarts(ctx context.Context, job *domain.AnalysisJob, c *restartCollector, files []domain.FileMetadata) {
+	events := DetectRestarts(c.samples, files)
+	if len(events) == 0 {
+		return
+	}
+	warmup := p.restartWarmup
+	if warmup == 0 {
+		warmup = DefaultRestartWarmup
+	}
+	events = restartsWithWarmup(events, warmup)
+
+	logger := slog.With("job_id", job.ID, "tenant_id", job.TenantID)
+	logger.Info("server restarts detected in capture", "restarts", len(events), "first_at", events[0].Timestamp)
+	if err := p.pg.UpdateJobRestarts(ctx, job.TenantID, job.ID, events); err != nil {
+		logger.Warn("failed to record server restarts", "error", err)
+		return
+	}
+	job.Restarts = events
+}
+
+// recordErrorOnset computes when the job's capture started to fail from
+// its stored entries and records it with the job. Failures are logged and
+// otherwise ignored.
+func (p *Pipeline) recordErrorOnset(ctx context.Context, job *domain.AnalysisJob) {
+	logger := slog.With("job_id", job.ID, "tenant_id", job.TenantID)
+	onset, err := p.ch.GetErrorOnset(ctx, job.TenantID.String(), job.ID.String())
+	if err != nil {
+		logger.Warn("error onset computation failed (non-fatal)", "error", err)
+		return
+	}
+	if err := p.pg.UpdateJobErrorOnset(ctx, job.TenantID, job.ID, onset); err != nil {
+		logger.Warn("failed to record error onset", "error", err)
+		return
+	}
+	if onset.FirstError != nil {
+		job.FirstErrorAt = onset.FirstError.Timestamp.TimePtr()
+		logger.Info("error onset recorded", "first_error_at", onset.FirstError.Timestamp, "total_errors", onset.TotalErrors)
+	}
+}
+
+// recordVocabulary adds the form, filter, table, queue and escalation names
+// of the job's stored entries to the tenant vocabulary, one statement per
+// field, then evicts values the tenant has not seen for a while. Failures
+// are logged and otherwise ignored. It returns the names read, nil when
+// they could not be.
+func (p *Pipeline) recordVocabulary(ctx context.Context, job domain.AnalysisJob) map[string][]domain.AutocompleteValue {
+	logger := slog.With("job_id", job.ID.String(), "tenant_id", job.TenantID.String())
+	vocabulary, err := p.ch.GetJobVocabulary(ctx, job.TenantID.String(), job.ID.String(), storage.MaxJobVocabularyValues)
+	if err != nil {
+		logger.Warn("vocabulary extraction failed (non-fatal)", "error", err)
+		return nil
+	}
+
+	recorded := 0
+	for _, field := range domain.VocabularyFields {
+		values := vocabulary[field]
+		if len(values) == 0 {
+			continue
+		}
+		n, err := p.pg.UpsertVocabulary(ctx, job.TenantID, job.ID, field, values)
+		if err != nil {
+			logger.Warn("failed to record vocabulary", "field", field, "error", err)
+			continue
+		}
+		recorded += n
+	}
+
+	months, maxValues := p.vocabularyMonths, p.vocabularyMaxValues
+	if months <= 0 {
+		months = DefaultVocabularyMonths
+	}
+	if maxValues <= 0 {
+		maxValues = DefaultVocabularyMaxValues
+	}
+	evicted, err := p.pg.EvictVocabulary(ctx, job.TenantID, p.now().AddDate(0, -months, 0), maxValues)
+	if err != nil {
+		logger.Warn("failed to evict vocabulary", "error", err)
+	}
+	logger.Info("tenant vocabulary updated", "values", recorded, "evicted", evicted)
+	return vocabulary
+}
+
+// resolveTruncatedNames recovers the full names of the filters and forms
+// the JAR truncated in its filter sections from the names stored for the
+// job, re-caching the section when it resolved any.
+func (p *Pipeline) resolveTruncatedNames(ctx context.Context, job domain.AnalysisJob, result *domain.ParseResult, vocabulary map[string][]domain.AutocompleteValue) {
+	if result == nil || result.JARFilters == nil {
+		return
+	}
+	values := func(field string) []string {
+		names := make([]string, 0, len(vocabulary[field]))
+		for _, v := range vocabulary[field] {
+			names = append(names, v.Value)
+		}
+		return names
+	}
+	resolved := jar.ResolveTruncatedNames(result.JARFilters, values("filter_name"), values("form"))
+	if resolved == 0 {
+		return
+	}
+	logger := slog.With("job_id", job.ID.String(), "tenant_id", job.TenantID.String())
+	logger.Info("truncated names resolved", "names", resolved)
+
+	if p.redis == nil || !sectionPresent(result.Sections, "filters") {
+		return
+	}
+	key := p.redis.TenantKey(job.TenantID.String(), "dashboard", job.ID.String()) + ":filters"
+	if err := p.redis.Set(ctx, key, result.JARFilters, p.dashboardCacheTTL()); err != nil {
+		logger.Warn("redis cache set failed", "section", "filters", "error", err)
+	}
+}
+
+// checkIntegrity validates the downloaded file and records the result on
+// the job. A file that cannot be read is logged and let through; the
+// report is returned so that the caller can fail the job on fatal issues.
+func (p *Pipeline) checkIntegrity(ctx context.Context, job *domain.AnalysisJob, path string) *domain.FileIntegrity {
+	logger := slog.With("job_id", job.ID.String(), "tenant_id", job.TenantID.String())
+
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Warn("file integrity check failed (non-fatal)", "error", err)
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		logger.Warn("file integrity check failed (non-fatal)", "error", err)
+		return nil
+	}
+	report, err := logparser.ValidateLogFile(f, info.Size())
+	if err != nil {
+		logger.Warn("file integrity check failed (non-fatal)", "error", err)
+		return nil
+	}
+
+	for _, issue := range report.Issues {
+		logger.Warn("file integrity issue",
+			"code", issue.Code,
+			"severity", issue.Severity,
+			"offset", issue.Offset,
+			"message", issue.Message,
+		)
+	}
+	job.Integrity = report
+	if err := p.pg.UpdateJobFileIntegrity(ctx, job.TenantID, job.ID, report); err != nil {
+		logger.Warn("failed to record file integrity", "error", err)
+	}
+	return report
+}
+
+// selectRunner samples the downloaded file, records the detected log format
+// on the job and returns the JAR runner able to analyse it. Files whose
+// format cannot be recognised go to the default JAR.
+func (p *Pipeline) selectRunner(ctx context.Context, job domain.AnalysisJob, path string) (JARRunner, error) {
+	logger := slog.With("job_id", job.ID.String(), "tenant_id", job.TenantID.String())
+
+	detection, err := logparser.DetectFileFormat(path)
+	if err != nil {
+		logger.Warn("log format detection failed, using default JAR", "error", err)
+		return p.jar, nil
+	}
+
+	logger.Info("log format detected",
+		"format", detection.Format,
+		"server_version", detection.ServerVersion,
+		"modern_lines", detection.ModernLines,
+		"legacy_lines", detection.LegacyLines,
+	)
+	if detection.Mixed {
+		logger.Warn("file mixes log formats; lines in the minority format may be skipped",
+			"modern_lines", detection.ModernLines,
+			"legacy_lines", detection.LegacyLines,
+		)
+	}
+	if err := p.pg.UpdateJobLogFormat(ctx, job.TenantID, job.ID, detection.Format); err != nil {
+		logger.Warn("failed to record job log format", "error", err)
+	}
+
+	switch detection.Format {
+	case domain.LogFormatAR25, domain.LogFormatUnknown:
+		return p.jar, nil
+	}
+	if runner, ok := p.legacy[detection.Format]; ok {
+		return runner, nil
+	}
+	return nil, fmt.Errorf("unsupported log format detected: looks like AR %s",
+		strings.TrimPrefix(string(detection.Format), "ar-"))
+}
+
+// recordResources persists the JAR process resource usage on the job row.
+// Failures are logged but never fail the job.
+func (p *Pipeline) recordResources(ctx context.Context, job domain.AnalysisJob, result *jar.Result) {
+	usage := domain.JobResourceUsage{
+		PeakRSSKB:  result.PeakRSSKB,
+		CPUTimeMS:  result.CPUTime.Milliseconds(),
+		WallTimeMS: result.Duration.Milliseconds(),
+	}
+	p.usage.Record(domain.UsageEvent{TenantID: job.TenantID, Metric: domain.UsageWorkerMS, SourceID: job.ID, Amount: usage.WallTimeMS})
+	if err := p.pg.UpdateJobResources(ctx, job.TenantID, job.ID, usage); err != nil {
+		slog.Warn("failed to record job resources", "job_id", job.ID.String(), "error", err)
+		return
+	}
+	slog.Info("job resources recorded",
+		"job_id", job.ID.String(),
+		"peak_rss_kb", usage.PeakRSSKB,
+		"cpu_time_ms", usage.CPUTimeMS,
+		"wall_time_ms", usage.WallTimeMS,
+	)
+}
+
+// RejectJob marks a job as failed without running it, e.g. when the worker
+// can never admit it.
+func (p *Pipeline) RejectJob(ctx context.Context, job domain.AnalysisJob, reason string) error {
+	return p.failJob(ctx, job, reason)
+}
+
 func (p *Pipeline) failJob(ctx context.Context, job domain.AnalysisJob, errMsg string) error {
+	// An inline analysis that overran its bounds goes to the queue instead.
+	if abort := inlineAborted(ctx); abort != nil {
+		return abort
+	}
+	// So does a stage that failed because the job was cancelled, and a job
+	// whose cancellation was requested before it failed.
+	if err := jobCancelled(ctx); err != nil {
+		return err
+	}
 	slog.Error("job failed", "job_id", job.ID.String(), "error", errMsg)
-	_ = p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusFailed, &errMsg)
-	_ = p.nats.PublishJobProgress(ctx, job.TenantID.String(), job.ID.String(), 0, string(domain.JobStatusFailed), errMsg)
+	if err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusFailed, &errMsg); errors.Is(err, storage.ErrJobCancelled) {
+		return ErrJobCancelled
+	}
+	p.publishProgress(ctx, job, 0, domain.JobStatusFailed, errMsg)
 
 	completedJob := job
 	completedJob.Status = domain.JobStatusFailed
 	completedJob.ErrorMessage = &errMsg
-	_ = p.nats.PublishJobComplete(ctx, job.TenantID.String(), job.ID.String(), completedJob)
+	p.publishComplete(ctx, completedJob)
+	p.recordEvent(job, domain.JobEventFailed, "", errMsg, nil)
 
 	return fmt.Errorf("%s", errMsg)
 }
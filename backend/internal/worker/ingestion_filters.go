@@ -0,0 +1,227 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+const (
+	// maxIngestionFilterRuleName bounds the length of a rule name.
+	maxIngestionFilterRuleName = 200
+	// maxIngestionFilterValue bounds the length of the value a rule matches.
+	maxIngestionFilterValue = 500
+)
+
+// ingestionFilterFields reads the fields an ingestion filter rule may test.
+// They are the text fields of the search whitelist; duration_ms, success and
+// error_encountered are not text and cannot be matched.
+var ingestionFilterFields = map[string]func(e *domain.LogEntry) string{
+	"log_type":    func(e *domain.LogEntry) string { return string(e.LogType) },
+	"user":        func(e *domain.LogEntry) string { return e.User },
+	"queue":       func(e *domain.LogEntry) string { return e.Queue },
+	"thread_id":   func(e *domain.LogEntry) string { return e.ThreadID },
+	"trace_id":    func(e *domain.LogEntry) string { return e.TraceID },
+	"rpc_id":      func(e *domain.LogEntry) string { return e.RPCID },
+	"api_code":    func(e *domain.LogEntry) string { return e.APICode },
+	"form":        func(e *domain.LogEntry) string { return e.Form },
+	"client":      func(e *domain.LogEntry) string { return e.Client },
+	"client_ip":   func(e *domain.LogEntry) string { return e.ClientIP },
+	"operation":   func(e *domain.LogEntry) string { return e.Operation },
+	"request_id":  func(e *domain.LogEntry) string { return e.RequestID },
+	"sql_table":   func(e *domain.LogEntry) string { return e.SQLTable },
+	"filter_name": func(e *domain.LogEntry) string { return e.FilterName },
+	"esc_name":    func(e *domain.LogEntry) string { return e.EscName },
+	"esc_pool":    func(e *domain.LogEntry) string { return e.EscPool },
+}
+
+// ValidateIngestionFilterRule checks a rule before it is stored and fills in
+// the default action.
+func ValidateIngestionFilterRule(rule *domain.IngestionFilterRule) error {
+	if rule.Name == "" || len(rule.Name) > maxIngestionFilterRuleName {
+		return fmt.Errorf("name is required and must be at most %d characters", maxIngestionFilterRuleName)
+	}
+
+	if !storage.IsKnownField(rule.Field) {
+		return fmt.Errorf("unknown field %q", rule.Field)
+	}
+	if _, ok := ingestionFilterFields[rule.Field]; !ok {
+		fields := make([]string, 0, len(ingestionFilterFields))
+		for f := range ingestionFilterFields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		return fmt.Errorf("field %s is not text; field must be one of %s", rule.Field, strings.Join(fields, ", "))
+	}
+
+	switch rule.Operator {
+	case domain.IngestionFilterEquals, domain.IngestionFilterPrefix, domain.IngestionFilterSuffix, domain.IngestionFilterContains:
+	default:
+		return fmt.Errorf("operator must be one of equals, prefix, suffix, contains")
+	}
+
+	if rule.Value == "" || len(rule.Value) > maxIngestionFilterValue {
+		return fmt.Errorf("value is required and must be at most %d characters", maxIngestionFilterValue)
+	}
+
+	switch rule.Action {
+	case "":
+		rule.Action = domain.IngestionFilterDrop
+	case domain.IngestionFilterDrop, domain.IngestionFilterFlag:
+	default:
+		return fmt.Errorf("action must be one of drop, flag")
+	}
+	return nil
+}
+
+// IngestionFilter applies a tenant's ingestion filter rules to the entries
+// of a capture and counts what each rule matched. An entry is counted
+// against the first rule it matches, in rule order, and that rule decides
+// whether it is dropped or flagged as noise.
+type IngestionFilter struct {
+	rules   []ingestionFilterRule
+	matched []int64
+	dropped map[domain.LogType]int64
+}
+
+// ingestionFilterRule is a rule prepared for matching.
+type ingestionFilterRule struct {
+	domain.IngestionFilterRule
+	field func(e *domain.LogEntry) string
+	value string // Lower-cased unless the rule is case sensitive
+}
+
+// NewIngestionFilter prepares the enabled rules of rules for matching.
+// Rules failing validation are skipped.
+func NewIngestionFilter(rules []domain.IngestionFilterRule) *IngestionFilter {
+	f := &IngestionFilter{}
+	for _, r := range rules {
+		if !r.Enabled || ValidateIngestionFilterRule(&r) != nil {
+			continue
+		}
+		value := r.Value
+		if !r.CaseSensitive {
+			value = strings.ToLower(value)
+		}
+		f.rules = append(f.rules, ingestionFilterRule{IngestionFilterRule: r, field: ingestionFilterFields[r.Field], value: value})
+	}
+	f.matched = make([]int64, len(f.rules))
+	f.dropped = make(map[domain.LogType]int64)
+	return f
+}
+
+// fresh returns a filter applying the same rules, with nothing matched yet.
+func (f *IngestionFilter) fresh() *IngestionFilter {
+	if f == nil {
+		return nil
+	}
+	return &IngestionFilter{rules: f.rules, matched: make([]int64, len(f.rules)), dropped: make(map[domain.LogType]int64)}
+}
+
+// Empty reports whether the filter has no rules to apply.
+func (f *IngestionFilter) Empty() bool {
+	return f == nil || len(f.rules) == 0
+}
+
+// Apply filters a batch in place. It returns the entries to store, with
+// those matched by a flag rule marked as noise.
+func (f *IngestionFilter) Apply(batch []domain.LogEntry) []domain.LogEntry {
+	if f.Empty() {
+		return batch
+	}
+	kept := batch[:0]
+	for i := range batch {
+		e := batch[i]
+		if r := f.match(&e); r >= 0 {
+			f.matched[r]++
+			if f.rules[r].Action == domain.IngestionFilterDrop {
+				f.dropped[e.LogType]++
+				continue
+			}
+			e.IsNoise = true
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// match returns the index of the first rule e matches, or -1.
+func (f *IngestionFilter) match(e *domain.LogEntry) int {
+	for i := range f.rules {
+		if f.rules[i].matches(e) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *ingestionFilterRule) matches(e *domain.LogEntry) bool {
+	v := r.field(e)
+	if !r.CaseSensitive {
+		v = strings.ToLower(v)
+	}
+	switch r.Operator {
+	case domain.IngestionFilterEquals:
+		return v == r.value
+	case domain.IngestionFilterPrefix:
+		return strings.HasPrefix(v, r.value)
+	case domain.IngestionFilterSuffix:
+		return strings.HasSuffix(v, r.value)
+	case domain.IngestionFilterContains:
+		return strings.Contains(v, r.value)
+	}
+	return false
+}
+
+// Stats returns the entries each rule matched so far, in rule order.
+func (f *IngestionFilter) Stats() []domain.IngestionFilterMatch {
+	if f.Empty() {
+		return nil
+	}
+	stats := make([]domain.IngestionFilterMatch, len(f.rules))
+	for i, r := range f.rules {
+		stats[i] = domain.IngestionFilterMatch{RuleID: r.ID, RuleName: r.Name, Action: r.Action, Matched: f.matched[i]}
+	}
+	return stats
+}
+
+// Totals returns the entries the filter dropped and flagged so far.
+func (f *IngestionFilter) Totals() (dropped, flagged int64) {
+	for _, s := range f.Stats() {
+		if s.Action == domain.IngestionFilterDrop {
+			dropped += s.Matched
+		} else {
+			flagged += s.Matched
+		}
+	}
+	return dropped, flagged
+}
+
+// DroppedByType returns the entries the filter dropped so far per log type.
+func (f *IngestionFilter) DroppedByType() map[domain.LogType]int64 {
+	if f.Empty() {
+		return nil
+	}
+	return f.dropped
+}
+
+// loadIngestionFilter loads the tenant's ingestion filter rules. A tenant
+// whose rules cannot be read has its capture stored unfiltered.
+func (p *Pipeline) loadIngestionFilter(ctx context.Context, tenantID uuid.UUID) *IngestionFilter {
+	if !p.ingestionFilters {
+		return nil
+	}
+	rules, err := p.pg.ListIngestionFilterRules(ctx, tenantID)
+	if err != nil {
+		slog.Warn("ingestion filter rules not available, storing every entry", "tenant_id", tenantID.String(), "error", err)
+		return nil
+	}
+	return NewIngestionFilter(rules)
+}
@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func filterRule(field string, op domain.IngestionFilterOperator, value string) domain.IngestionFilterRule {
+	return domain.IngestionFilterRule{ID: uuid.New(), Name: field + " " + string(op), Field: field, Operator: op, Value: value, Enabled: true}
+}
+
+func TestIngestionFilter_Operators(t *testing.T) {
+	tests := []struct {
+		name          string
+		op            domain.IngestionFilterOperator
+		value         string
+		caseSensitive bool
+		form          string
+		want          bool
+	}{
+		{"equals", domain.IngestionFilterEquals, "AR System Monitor", false, "AR System Monitor", true},
+		{"equals ignores case", domain.IngestionFilterEquals, "ar system monitor", false, "AR System Monitor", true},
+		{"equals is whole value", domain.IngestionFilterEquals, "AR System", false, "AR System Monitor", false},
+		{"equals case sensitive", domain.IngestionFilterEquals, "ar system monitor", true, "AR System Monitor", false},
+		{"prefix", domain.IngestionFilterPrefix, "AR System", false, "AR System Monitor", true},
+		{"prefix ignores case", domain.IngestionFilterPrefix, "ar SYSTEM", false, "AR System Monitor", true},
+		{"prefix case sensitive", domain.IngestionFilterPrefix, "ar System", true, "AR System Monitor", false},
+		{"prefix not at start", domain.IngestionFilterPrefix, "Monitor", false, "AR System Monitor", false},
+		{"suffix", domain.IngestionFilterSuffix, "Monitor", false, "AR System Monitor", true},
+		{"suffix ignores case", domain.IngestionFilterSuffix, "MONITOR", false, "AR System Monitor", true},
+		{"suffix case sensitive", domain.IngestionFilterSuffix, "MONITOR", true, "AR System Monitor", false},
+		{"suffix not at end", domain.IngestionFilterSuffix, "System", false, "AR System Monitor", false},
+		{"contains", domain.IngestionFilterContains, "System", false, "AR System Monitor", true},
+		{"contains ignores case", domain.IngestionFilterContains, "sYsTeM", false, "AR System Monitor", true},
+		{"contains case sensitive", domain.IngestionFilterContains, "system", true, "AR System Monitor", false},
+		{"contains missing", domain.IngestionFilterContains, "Help Desk", false, "AR System Monitor", false},
+		{"empty field", domain.IngestionFilterContains, "System", false, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := filterRule("form", tt.op, tt.value)
+			rule.CaseSensitive = tt.caseSensitive
+			f := NewIngestionFilter([]domain.IngestionFilterRule{rule})
+
+			kept := f.Apply([]domain.LogEntry{{Form: tt.form}})
+			assert.Equal(t, tt.want, len(kept) == 0)
+			assert.Equal(t, map[bool]int64{true: 1, false: 0}[tt.want], f.Stats()[0].Matched)
+		})
+	}
+}
+
+func TestIngestionFilter_DropAndFlag(t *testing.T) {
+	probe := filterRule("user", domain.IngestionFilterEquals, "HealthCheckUser")
+	monitor := filterRule("form", domain.IngestionFilterPrefix, "AR System Monitor")
+	monitor.Action = domain.IngestionFilterFlag
+	disabled := filterRule("user", domain.IngestionFilterEquals, "Demo")
+	disabled.Enabled = false
+	invalid := filterRule("duration_ms", domain.IngestionFilterEquals, "0")
+	f := NewIngestionFilter([]domain.IngestionFilterRule{probe, monitor, disabled, invalid})
+
+	batch := []domain.LogEntry{
+		{EntryID: "1", User: "healthcheckuser", Form: "AR System Monitor"},
+		{EntryID: "2", User: "Demo", Form: "AR System Monitor Log"},
+		{EntryID: "3", User: "Demo", Form: "HPD:Help Desk"},
+	}
+	kept := f.Apply(batch)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "2", kept[0].EntryID)
+	assert.True(t, kept[0].IsNoise, "flagged entries are stored as noise")
+	assert.Equal(t, "3", kept[1].EntryID)
+	assert.False(t, kept[1].IsNoise)
+
+	// The probe entry matches both rules but only counts against the first.
+	stats := f.Stats()
+	require.Len(t, stats, 2, "disabled and invalid rules are not applied")
+	assert.Equal(t, domain.IngestionFilterMatch{RuleID: probe.ID, RuleName: probe.Name, Action: domain.IngestionFilterDrop, Matched: 1}, stats[0])
+	assert.Equal(t, domain.IngestionFilterMatch{RuleID: monitor.ID, RuleName: monitor.Name, Action: domain.IngestionFilterFlag, Matched: 1}, stats[1])
+
+	dropped, flagged := f.Totals()
+	assert.Equal(t, int64(1), dropped)
+	assert.Equal(t, int64(1), flagged)
+}
+
+func TestIngestionFilter_Empty(t *testing.T) {
+	var f *IngestionFilter
+	assert.True(t, f.Empty())
+	batch := []domain.LogEntry{{User: "HealthCheckUser"}}
+	assert.Equal(t, batch, f.Apply(batch))
+	assert.Nil(t, f.Stats())
+
+	assert.True(t, NewIngestionFilter(nil).Empty())
+}
+
+func TestValidateIngestionFilterRule(t *testing.T) {
+	valid := func() *domain.IngestionFilterRule {
+		r := filterRule("user", domain.IngestionFilterEquals, "HealthCheckUser")
+		return &r
+	}
+
+	r := valid()
+	require.NoError(t, ValidateIngestionFilterRule(r))
+	assert.Equal(t, domain.IngestionFilterDrop, r.Action, "the action defaults to drop")
+
+	tests := []struct {
+		name   string
+		modify func(r *domain.IngestionFilterRule)
+		want   string
+	}{
+		{"missing name", func(r *domain.IngestionFilterRule) { r.Name = "" }, "name is required"},
+		{"unknown field", func(r *domain.IngestionFilterRule) { r.Field = "raw_text" }, `unknown field "raw_text"`},
+		{"numeric field", func(r *domain.IngestionFilterRule) { r.Field = "duration_ms" }, "field duration_ms is not text"},
+		{"bad operator", func(r *domain.IngestionFilterRule) { r.Operator = "regex" }, "operator must be one of"},
+		{"empty value", func(r *domain.IngestionFilterRule) { r.Value = "" }, "value is required"},
+		{"bad action", func(r *domain.IngestionFilterRule) { r.Action = "hide" }, "action must be one of drop, flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(r)
+			err := ValidateIngestionFilterRule(r)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestPipeline_LoadIngestionFilter(t *testing.T) {
+	tenantID := uuid.New()
+	pg := &testutil.MockPostgresStore{}
+	p := NewPipeline(pg, nil, nil, nil, nil, nil, nil)
+	assert.Nil(t, p.loadIngestionFilter(context.Background(), tenantID), "rules are not read unless enabled")
+
+	p.SetIngestionFilters(true)
+	pg.On("ListIngestionFilterRules", mock.Anything, tenantID).
+		Return([]domain.IngestionFilterRule{filterRule("user", domain.IngestionFilterEquals, "HealthCheckUser")}, nil).Once()
+	f := p.loadIngestionFilter(context.Background(), tenantID)
+	require.False(t, f.Empty())
+	assert.Len(t, f.Stats(), 1)
+
+	pg.On("ListIngestionFilterRules", mock.Anything, tenantID).Return(nil, errors.New("db down")).Once()
+	assert.True(t, p.loadIngestionFilter(context.Background(), tenantID).Empty(), "the capture is stored unfiltered")
+	pg.AssertExpectations(t)
+}
@@ -2,7 +2,9 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -14,8 +16,10 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
 	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
 )
 
@@ -76,6 +80,22 @@ Demo:  6500
 HPD:Help Desk:  5000
 `
 
+// validJARCounts are the entries per log type validJAROutput reports.
+var validJARCounts = map[domain.LogType]int64{
+	domain.LogTypeAPI:        8901,
+	domain.LogTypeSQL:        2345,
+	domain.LogTypeFilter:     890,
+	domain.LogTypeEscalation: 209,
+}
+
+// expectReconciliation has ch report stored the entries per log type in
+// stored, and pg accept the reconciliation of a job. The count reads back
+// the entries the pipeline just inserted, so it must not go to a replica.
+func expectReconciliation(pg *testutil.MockPostgresStore, ch *testutil.MockClickHouseStore, job domain.AnalysisJob, stored map[domain.LogType]int64) {
+	ch.On("CountJobEntriesByType", mock.MatchedBy(storage.UsesPrimary), job.TenantID.String(), job.ID.String()).Return(stored, nil).Maybe()
+	pg.On("UpdateJobReconciliation", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.IngestionReconciliation")).Return(nil).Maybe()
+}
+
 // newTestJob creates a standard AnalysisJob for use in tests.
 func newTestJob() domain.AnalysisJob {
 	return domain.AnalysisJob{
@@ -96,6 +116,22 @@ func TestNewPipeline(t *testing.T) {
 	assert.NotNil(t, p)
 }
 
+func TestPipeline_DynamicSettings(t *testing.T) {
+	p := NewPipeline(nil, nil, nil, nil, nil, nil, nil)
+	assert.Equal(t, DefaultDashboardCacheTTL, p.dashboardCacheTTL())
+	assert.Equal(t, int64(DefaultProgressEveryLines), p.progressEveryLines())
+
+	settings := config.NewDynamicSettings(&config.Config{})
+	p.SetDynamic(settings)
+	_, err := settings.Apply(&domain.ServerSettings{Version: 1, Settings: map[string]json.RawMessage{
+		"cache.dashboard_ttl_sec": json.RawMessage("3600"),
+		"progress.every_lines":    json.RawMessage("250"),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, p.dashboardCacheTTL())
+	assert.Equal(t, int64(250), p.progressEveryLines())
+}
+
 // TestProcessJob_NilPGClient verifies that ProcessJob panics when the pg
 // client is nil because the first operation calls a method on a nil interface.
 func TestProcessJob_NilPGClient(t *testing.T) {
@@ -173,12 +209,52 @@ func TestProcessJob_GetLogFileFails(t *testing.T) {
 	nats.AssertExpectations(t)
 }
 
+// failingRegions is a RegionResolver that cannot resolve any tenant.
+type failingRegions struct{ err error }
+
+func (f failingRegions) TenantRegion(ctx context.Context, tenantID string) (string, error) {
+	return "", f.err
+}
+
+func (f failingRegions) RefreshTenant(ctx context.Context, tenantID string) (string, error) {
+	return "", f.err
+}
+
+func (f failingRegions) ObjectStorage(ctx context.Context, tenantID string) (storage.ObjectStorage, error) {
+	return nil, f.err
+}
+
+// TestProcessJob_RegionUnresolvedFails verifies that a job whose tenant's
+// storage region cannot be resolved fails before its file is read.
+func TestProcessJob_RegionUnresolvedFails(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+	job := newTestJob()
+
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).
+		Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), 0, "failed", mock.AnythingOfType("string")).
+		Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).
+		Return(nil)
+
+	p := NewPipeline(pg, nil, nil, nil, nats, nil, nil)
+	p.SetRegions(failingRegions{err: errors.New(`tenant is pinned to region "apac", which is not configured`)})
+	err := p.ProcessJob(context.Background(), job)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resolve storage region")
+	pg.AssertNotCalled(t, "GetLogFile", mock.Anything, mock.Anything, mock.Anything)
+	pg.AssertExpectations(t)
+	nats.AssertExpectations(t)
+}
+
 // TestProcessJob_S3DownloadFails verifies that when S3 download fails,
 // ProcessJob calls failJob and returns the error.
 func TestProcessJob_S3DownloadFails(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	job := newTestJob()
 
 	file := &domain.LogFile{
@@ -224,7 +300,7 @@ func TestProcessJob_S3DownloadFails(t *testing.T) {
 func TestProcessJob_JARFails(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -247,10 +323,14 @@ func TestProcessJob_JARFails(t *testing.T) {
 	logContent := "sample log content"
 	s3.On("Download", mock.Anything, "logs/test.log").
 		Return(io.NopCloser(strings.NewReader(logContent)), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 
 	// Step 4: JAR fails with stderr
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stderr: "OutOfMemoryError"}, errors.New("exit code 1"))
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).
+		Return(nil)
 
 	// failJob calls
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).
@@ -268,12 +348,120 @@ func TestProcessJob_JARFails(t *testing.T) {
 	jarRunner.AssertExpectations(t)
 }
 
+// legacyLogContent is an AR Server 9.x log snippet: no TrID field.
+const legacyLogContent = `<API > <TID: 0000000212> <RPC ID: 0000004411> <Queue: Fast      > <Client-RPC: 390620   > <USER: Demo                 > <Overlay-Group: 1         > /* Mon Feb 02 2026 08:00:01.1010 */ +GE    ARGetEntry -- schema HPD:Help Desk
+<API > <TID: 0000000212> <RPC ID: 0000004411> <Queue: Fast      > <Client-RPC: 390620   > <USER: Demo                 > <Overlay-Group: 1         > /* Mon Feb 02 2026 08:00:01.1120 */ -GE             OK
+`
+
+// TestProcessJob_LegacyFormatWithoutRunnerFails verifies that a 9.x log is
+// rejected before the JAR runs when no legacy JAR is configured.
+func TestProcessJob_LegacyFormatWithoutRunnerFails(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusParsing, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(&domain.LogFile{S3Key: "logs/test.log"}, nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader(legacyLogContent)), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, domain.LogFormatAR9).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed,
+		mock.MatchedBy(func(msg *string) bool {
+			return msg != nil && *msg == "unsupported log format detected: looks like AR 9.x"
+		})).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+
+	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	err := p.ProcessJob(context.Background(), job)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "looks like AR 9.x")
+	pg.AssertExpectations(t)
+	jarRunner.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestProcessJob_CorruptFileFailsBeforeJAR verifies that a binary upload
+// fails the job with an error code, and that the integrity report reaches
+// the job_complete payload.
+func TestProcessJob_CorruptFileFailsBeforeJAR(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+
+	content := legacyLogContent + strings.Repeat("\x00", 512)
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusParsing, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(&domain.LogFile{S3Key: "logs/test.log"}, nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader(content)), nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID,
+		mock.MatchedBy(func(r *domain.FileIntegrity) bool {
+			return r.Fatal() != nil && r.Fatal().Code == domain.IntegrityBinaryContent
+		})).Return(nil)
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed,
+		mock.MatchedBy(func(msg *string) bool {
+			return msg != nil && strings.HasPrefix(*msg, "corrupt log file: ")
+		})).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(),
+		mock.MatchedBy(func(j domain.AnalysisJob) bool {
+			return j.Status == domain.JobStatusFailed &&
+				j.ErrorCode != nil && *j.ErrorCode == domain.IntegrityBinaryContent &&
+				j.Integrity != nil && len(j.Integrity.Issues) > 0
+		})).Return(nil)
+
+	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	err := p.ProcessJob(context.Background(), job)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "binary data")
+	assert.Contains(t, err.Error(), fmt.Sprintf("byte %d", len(legacyLogContent)))
+	pg.AssertExpectations(t)
+	nats.AssertExpectations(t)
+	pg.AssertNotCalled(t, "UpdateJobLogFormat", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	jarRunner.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestProcessJob_LegacyFormatUsesLegacyRunner verifies that a 9.x log is
+// analysed by the JAR registered for that format.
+func TestProcessJob_LegacyFormatUsesLegacyRunner(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	defaultRunner := &MockJARRunner{}
+	legacyRunner := &MockJARRunner{}
+	job := newTestJob()
+
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusParsing, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(&domain.LogFile{S3Key: "logs/test.log"}, nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader(legacyLogContent)), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, domain.LogFormatAR9).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
+	legacyRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(nil, errors.New("exit code 1"))
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+
+	p := NewPipeline(pg, nil, s3, nil, nats, defaultRunner, nil)
+	p.SetLegacyRunners(map[domain.LogFormat]JARRunner{domain.LogFormatAR9: legacyRunner})
+	err := p.ProcessJob(context.Background(), job)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JAR execution failed")
+	legacyRunner.AssertExpectations(t)
+	defaultRunner.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 // TestProcessJob_JARFailsWithNilResult verifies that when JAR returns a nil
 // result and an error, the stderr is empty in the error message.
 func TestProcessJob_JARFailsWithNilResult(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -293,6 +481,8 @@ func TestProcessJob_JARFailsWithNilResult(t *testing.T) {
 		Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").
 		Return(io.NopCloser(strings.NewReader("log data")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 
 	// Step 4: JAR returns nil result with error
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
@@ -317,7 +507,7 @@ func TestProcessJob_JARFailsWithNilResult(t *testing.T) {
 func TestProcessJob_ParseOutputFails(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -337,10 +527,14 @@ func TestProcessJob_ParseOutputFails(t *testing.T) {
 		Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").
 		Return(io.NopCloser(strings.NewReader("log data")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 
 	// Step 4: JAR succeeds but with empty/invalid output
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stdout: "", Stderr: ""}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).
+		Return(nil)
 
 	// failJob calls
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).
@@ -359,8 +553,9 @@ func TestProcessJob_ParseOutputFails(t *testing.T) {
 // ProcessJob without Redis or anomaly detection.
 func TestProcessJob_SuccessfulCompletion(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -384,6 +579,8 @@ func TestProcessJob_SuccessfulCompletion(t *testing.T) {
 	// Step 3: Download file
 	s3.On("Download", mock.Anything, "logs/test.log").
 		Return(io.NopCloser(strings.NewReader("sample log content")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 
 	// Step 4: Run JAR with valid output
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
@@ -393,10 +590,14 @@ func TestProcessJob_SuccessfulCompletion(t *testing.T) {
 			ExitCode: 0,
 			Duration: 5 * time.Second,
 		}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, domain.JobResourceUsage{WallTimeMS: 5000}).
+		Return(nil)
 
 	// Step 6: Update status to storing
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).
 		Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
 
 	// Step 8: Update status to complete
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).
@@ -408,7 +609,9 @@ func TestProcessJob_SuccessfulCompletion(t *testing.T) {
 	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).
 		Return(nil)
 
-	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	expectReconciliation(pg, ch, job, validJARCounts)
+
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
 	err := p.ProcessJob(context.Background(), job)
 
 	assert.NoError(t, err)
@@ -422,8 +625,9 @@ func TestProcessJob_SuccessfulCompletion(t *testing.T) {
 // runs when an AnomalyDetector is configured in the pipeline.
 func TestProcessJob_SuccessWithAnomalyDetector(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	detector := NewAnomalyDetector(3.0)
 	job := newTestJob()
@@ -439,26 +643,360 @@ func TestProcessJob_SuccessWithAnomalyDetector(t *testing.T) {
 	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
 	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stdout: validJAROutput, Duration: 1 * time.Second}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
 	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
 	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
 
-	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, detector)
+	expectReconciliation(pg, ch, job, validJARCounts)
+
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, detector)
 	err := p.ProcessJob(context.Background(), job)
 
 	assert.NoError(t, err)
 	pg.AssertExpectations(t)
 }
 
+// TestProcessJob_StrictParseStoresDiagnostics verifies that a job flagged
+// for strict parsing stores the parse diagnostics.
+func TestProcessJob_StrictParseStoresDiagnostics(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	job.JARFlags.StrictParse = true
+
+	file := &domain.LogFile{
+		ID:        job.FileID,
+		TenantID:  job.TenantID,
+		S3Key:     "logs/test.log",
+		SizeBytes: 1024,
+	}
+
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusParsing, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: validJAROutput, Duration: 1 * time.Second}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("UpdateJobParseDiagnostics", mock.Anything, job.TenantID, job.ID, mock.MatchedBy(func(d *domain.ParseDiagnostics) bool {
+		return d.SectionsSeen > 0 && d.SectionsRecognized == d.SectionsSeen
+	})).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+
+	expectReconciliation(pg, ch, job, validJARCounts)
+
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
+	err := p.ProcessJob(context.Background(), job)
+
+	assert.NoError(t, err)
+	pg.AssertExpectations(t)
+}
+
+// TestRecordErrorOnset verifies that the error onset computed from the
+// stored entries is saved and its first error copied onto the job.
+func TestRecordErrorOnset(t *testing.T) {
+	firstErr := time.Date(2026, 2, 3, 10, 4, 0, 0, time.UTC)
+
+	t.Run("records onset and first error", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		job := newTestJob()
+		onset := &domain.ErrorOnset{
+			JobID:       job.ID.String(),
+			TotalErrors: 3,
+			FirstError:  &domain.FirstError{Key: "API", Timestamp: domain.NewTimestamp(firstErr), EntryID: "e1"},
+		}
+		ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).Return(onset, nil)
+		pg.On("UpdateJobErrorOnset", mock.Anything, job.TenantID, job.ID, onset).Return(nil)
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		p.recordErrorOnset(context.Background(), &job)
+
+		require.NotNil(t, job.FirstErrorAt)
+		assert.Equal(t, firstErr, *job.FirstErrorAt)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("capture without errors", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		job := newTestJob()
+		onset := &domain.ErrorOnset{JobID: job.ID.String(), TotalEntries: 10}
+		ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).Return(onset, nil)
+		pg.On("UpdateJobErrorOnset", mock.Anything, job.TenantID, job.ID, onset).Return(nil)
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		p.recordErrorOnset(context.Background(), &job)
+
+		assert.Nil(t, job.FirstErrorAt)
+		pg.AssertExpectations(t)
+	})
+
+	t.Run("clickhouse failure is not recorded", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		job := newTestJob()
+		ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil, errors.New("ch down"))
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		p.recordErrorOnset(context.Background(), &job)
+
+		assert.Nil(t, job.FirstErrorAt)
+		pg.AssertNotCalled(t, "UpdateJobErrorOnset", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRecordVocabulary(t *testing.T) {
+	t.Run("one upsert per field then eviction", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		job := newTestJob()
+
+		filters := make([]domain.AutocompleteValue, 5000)
+		for i := range filters {
+			filters[i] = domain.AutocompleteValue{Value: fmt.Sprintf("HPD:Filter %d", i), Count: 1}
+		}
+		forms := []domain.AutocompleteValue{{Value: "HPD:Help Desk", Count: 42}}
+		ch.On("GetJobVocabulary", mock.Anything, job.TenantID.String(), job.ID.String(), storage.MaxJobVocabularyValues).
+			Return(map[string][]domain.AutocompleteValue{"form": forms, "filter_name": filters}, nil)
+		pg.On("UpsertVocabulary", mock.Anything, job.TenantID, job.ID, "form", forms).Return(1, nil).Once()
+		pg.On("UpsertVocabulary", mock.Anything, job.TenantID, job.ID, "filter_name", filters).Return(5000, nil).Once()
+		pg.On("EvictVocabulary", mock.Anything, job.TenantID,
+			mock.MatchedBy(func(cutoff time.Time) bool {
+				want := time.Now().UTC().AddDate(0, -3, 0)
+				return cutoff.Sub(want).Abs() < time.Minute
+			}), 100).Return(int64(7), nil)
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		p.SetVocabularyLimits(3, 100)
+		p.recordVocabulary(context.Background(), job)
+
+		pg.AssertExpectations(t)
+		pg.AssertNumberOfCalls(t, "UpsertVocabulary", 2)
+	})
+
+	t.Run("defaults bound the vocabulary", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		job := newTestJob()
+		ch.On("GetJobVocabulary", mock.Anything, job.TenantID.String(), job.ID.String(), storage.MaxJobVocabularyValues).
+			Return(map[string][]domain.AutocompleteValue{}, nil)
+		pg.On("EvictVocabulary", mock.Anything, job.TenantID, mock.AnythingOfType("time.Time"), DefaultVocabularyMaxValues).Return(int64(0), nil)
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		p.recordVocabulary(context.Background(), job)
+
+		pg.AssertExpectations(t)
+		pg.AssertNotCalled(t, "UpsertVocabulary", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("clickhouse failure is not recorded", func(t *testing.T) {
+		pg := &testutil.MockPostgresStore{}
+		ch := &testutil.MockClickHouseStore{}
+		job := newTestJob()
+		ch.On("GetJobVocabulary", mock.Anything, job.TenantID.String(), job.ID.String(), storage.MaxJobVocabularyValues).
+			Return(nil, errors.New("ch down"))
+
+		p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+		p.recordVocabulary(context.Background(), job)
+
+		pg.AssertNotCalled(t, "UpsertVocabulary", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		pg.AssertNotCalled(t, "EvictVocabulary", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestPipeline_ResolveTruncatedNames(t *testing.T) {
+	job := newTestJob()
+	vocabulary := map[string][]domain.AutocompleteValue{
+		"filter_name": {{Value: "HPD:INC:SendNotification", Count: 3}},
+		"form":        {{Value: "HPD:Help Desk", Count: 9}},
+	}
+	newResult := func() *domain.ParseResult {
+		return &domain.ParseResult{JARFilters: &domain.JARFilterComplexityResponse{
+			MostExecuted:   []domain.JARFilterMostExecuted{{FilterName: "HPD:INC:SendNotific" + jar.TruncationMarker}},
+			PerTransaction: []domain.JARFilterPerTransaction{{Form: "HPD:Help" + jar.TruncationMarker}},
+			Source:         "jar_parsed",
+		}}
+	}
+
+	t.Run("resolved names are re-cached", func(t *testing.T) {
+		redis := &testutil.MockRedisCache{}
+		result := newResult()
+		cacheKey := "t:" + job.TenantID.String() + ":dashboard:" + job.ID.String()
+		redis.On("TenantKey", job.TenantID.String(), "dashboard", job.ID.String()).Return(cacheKey)
+		redis.On("Set", mock.Anything, cacheKey+":filters", result.JARFilters, DefaultDashboardCacheTTL).Return(nil).Once()
+
+		p := NewPipeline(nil, nil, nil, redis, nil, nil, nil)
+		p.resolveTruncatedNames(context.Background(), job, result, vocabulary)
+
+		assert.Equal(t, "HPD:INC:SendNotification", result.JARFilters.MostExecuted[0].FilterName)
+		assert.Equal(t, domain.NameResolved, result.JARFilters.MostExecuted[0].FilterNameTruncated.Confidence)
+		assert.Equal(t, "HPD:Help Desk", result.JARFilters.PerTransaction[0].Form)
+		redis.AssertExpectations(t)
+	})
+
+	t.Run("nothing resolved is not re-cached", func(t *testing.T) {
+		redis := &testutil.MockRedisCache{}
+		result := newResult()
+
+		p := NewPipeline(nil, nil, nil, redis, nil, nil, nil)
+		p.resolveTruncatedNames(context.Background(), job, result, nil)
+
+		assert.Equal(t, "HPD:INC:SendNotific", result.JARFilters.MostExecuted[0].FilterName)
+		assert.Equal(t, domain.NameUnresolved, result.JARFilters.MostExecuted[0].FilterNameTruncated.Confidence)
+		redis.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestPipeline_JobParseOptions(t *testing.T) {
+	p := NewPipeline(nil, nil, nil, nil, nil, nil, nil)
+	p.SetParseOptions(jar.ParseOptions{MaxSectionRows: 10})
+
+	job := newTestJob()
+	assert.Equal(t, jar.ParseOptions{MaxSectionRows: 10}, p.jobParseOptions(job))
+
+	job.JARFlags.StrictParse = true
+	assert.Equal(t, jar.ParseOptions{MaxSectionRows: 10, Strict: true}, p.jobParseOptions(job))
+}
+
+func TestPipeline_JobOutputFormat(t *testing.T) {
+	p := NewPipeline(nil, nil, nil, nil, nil, nil, nil)
+	job := newTestJob()
+	assert.Empty(t, p.jobOutputFormat(job))
+
+	p.SetOutputFormat(domain.JAROutputXML)
+	assert.Equal(t, domain.JAROutputXML, p.jobOutputFormat(job))
+
+	job.JARFlags.OutputFormat = domain.JAROutputJSON
+	assert.Equal(t, domain.JAROutputJSON, p.jobOutputFormat(job))
+
+	job.JARFlags.OutputFormat = domain.JAROutputText
+	assert.Empty(t, p.jobOutputFormat(job), "text overrides the pipeline default")
+}
+
+// expectStructuredJob sets up the mocks of a job that runs to completion,
+// leaving the JAR runner to the caller.
+func expectStructuredJob(pg *testutil.MockPostgresStore, nats *testutil.MockNATSStreamer, s3 *testutil.MockObjectStorage, job domain.AnalysisJob) {
+	file := &domain.LogFile{ID: job.FileID, TenantID: job.TenantID, S3Key: "logs/test.log", SizeBytes: 1024}
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobStatus"), (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("sample log content")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
+	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+}
+
+// TestProcessJob_StructuredOutput verifies that a JSON report is parsed as
+// such when the pipeline asks the JAR for one.
+func TestProcessJob_StructuredOutput(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	expectStructuredJob(pg, nats, s3, job)
+
+	flags := job.JARFlags
+	flags.OutputFormat = domain.JAROutputJSON
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), flags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{
+			Stdout: `{"generalStatistics": {"apiCount": 8901, "startTime": "2026-02-03T10:00:00", "endTime": "2026-02-03T18:30:45", "elapsedTime": 30645}}`,
+		}, nil).Once()
+
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
+	p.SetOutputFormat(domain.JAROutputJSON)
+	require.NoError(t, p.ProcessJob(context.Background(), job))
+
+	jarRunner.AssertExpectations(t)
+	pg.AssertCalled(t, "UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil))
+}
+
+// TestProcessJob_StoresThreadCounts verifies that the threads configured per
+// queue are kept with the job.
+func TestProcessJob_StoresThreadCounts(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	expectStructuredJob(pg, nats, s3, job)
+	pg.On("UpdateJobThreadCounts", mock.Anything, job.TenantID, job.ID, map[string]int{"Fast": 4, "List": 20}).Return(nil).Once()
+
+	flags := job.JARFlags
+	flags.OutputFormat = domain.JAROutputJSON
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), flags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{
+			Stdout: `{"generalStatistics": {"apiCount": 12, "threadCounts": [{"queue": "Fast", "threads": 4}, {"queue": "List", "threads": 20}]}}`,
+		}, nil).Once()
+
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
+	p.SetOutputFormat(domain.JAROutputJSON)
+	require.NoError(t, p.ProcessJob(context.Background(), job))
+
+	pg.AssertExpectations(t)
+}
+
+// TestProcessJob_StructuredOutputFallsBackToText verifies that a JAR that
+// rejects -of is run again for its text report.
+func TestProcessJob_StructuredOutputFallsBackToText(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	job.JARFlags.OutputFormat = domain.JAROutputXML
+	expectStructuredJob(pg, nats, s3, job)
+
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{ExitCode: 1, Stderr: "Unknown option: -of"}, errors.New("jar runner: non-zero exit code 1: Unknown option: -of")).Once()
+	textFlags := job.JARFlags
+	textFlags.OutputFormat = ""
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), textFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: validJAROutput}, nil).Once()
+
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
+	require.NoError(t, p.ProcessJob(context.Background(), job))
+
+	jarRunner.AssertExpectations(t)
+	pg.AssertCalled(t, "UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil))
+}
+
 // TestProcessJob_SuccessWithRedis verifies that Redis caching is invoked
 // when a RedisCache is provided.
 func TestProcessJob_SuccessWithRedis(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	redis := &testutil.MockRedisCache{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
@@ -474,8 +1012,12 @@ func TestProcessJob_SuccessWithRedis(t *testing.T) {
 	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
 	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stdout: validJAROutput, Duration: 1 * time.Second}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).
+		Return(nil)
 
 	// Redis caching: TenantKey is called, then Set for each cached section
 	cachePrefix := "t:" + job.TenantID.String() + ":dashboard:" + job.ID.String()
@@ -486,11 +1028,14 @@ func TestProcessJob_SuccessWithRedis(t *testing.T) {
 	redis.On("Set", mock.Anything, mock.AnythingOfType("string"), mock.Anything, 24*time.Hour).Return(nil).Maybe()
 
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
 	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
 	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
 
-	p := NewPipeline(pg, nil, s3, redis, nats, jarRunner, nil)
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, redis, nats, jarRunner, nil)
 	err := p.ProcessJob(context.Background(), job)
 
 	assert.NoError(t, err)
@@ -503,8 +1048,9 @@ func TestProcessJob_SuccessWithRedis(t *testing.T) {
 // to mark the job as failed and return an error.
 func TestProcessJob_CompleteStatusUpdateFails(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -519,9 +1065,14 @@ func TestProcessJob_CompleteStatusUpdateFails(t *testing.T) {
 	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
 	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stdout: validJAROutput, Duration: 1 * time.Second}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
 
 	// Step 8: Complete status update fails
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).
@@ -531,7 +1082,8 @@ func TestProcessJob_CompleteStatusUpdateFails(t *testing.T) {
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).
 		Return(nil)
 
-	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
 	err := p.ProcessJob(context.Background(), job)
 
 	require.Error(t, err)
@@ -544,8 +1096,9 @@ func TestProcessJob_CompleteStatusUpdateFails(t *testing.T) {
 // status update fails, ProcessJob continues (non-fatal) and still completes.
 func TestProcessJob_StoringStatusUpdateFails(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -560,19 +1113,26 @@ func TestProcessJob_StoringStatusUpdateFails(t *testing.T) {
 	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
 	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stdout: validJAROutput, Duration: 1 * time.Second}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).
+		Return(nil)
 
 	// Storing update fails (non-fatal)
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).
 		Return(errors.New("pg timeout"))
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
 
 	// Complete still succeeds
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
 	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
 	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
 
-	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
 	err := p.ProcessJob(context.Background(), job)
 
 	assert.NoError(t, err, "storing status failure is non-fatal, job should complete")
@@ -583,8 +1143,9 @@ func TestProcessJob_StoringStatusUpdateFails(t *testing.T) {
 // fails at 100%, it is non-fatal and the job still completes.
 func TestProcessJob_UpdateProgressFails(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	jarRunner := &MockJARRunner{}
 	job := newTestJob()
 
@@ -599,9 +1160,14 @@ func TestProcessJob_UpdateProgressFails(t *testing.T) {
 	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
 	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
 	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("log")), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
 	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
 		Return(&jar.Result{Stdout: validJAROutput, Duration: 1 * time.Second}, nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
 	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
 
 	// Progress update fails (non-fatal)
@@ -610,7 +1176,8 @@ func TestProcessJob_UpdateProgressFails(t *testing.T) {
 
 	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
 
-	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	expectReconciliation(pg, ch, job, validJARCounts)
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
 	err := p.ProcessJob(context.Background(), job)
 
 	assert.NoError(t, err, "progress update failure is non-fatal")
@@ -719,7 +1286,7 @@ func TestPipelineFieldsStoredCorrectly(t *testing.T) {
 func TestPipelineFieldsStoredWithMocks(t *testing.T) {
 	pg := &testutil.MockPostgresStore{}
 	nats := &testutil.MockNATSStreamer{}
-	s3 := &testutil.MockS3Storage{}
+	s3 := &testutil.MockObjectStorage{}
 	redis := &testutil.MockRedisCache{}
 	jarRunner := &MockJARRunner{}
 	detector := NewAnomalyDetector(3.0)
@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// ErrInlineFallback is returned by InlineRunner.Run when a job was not
+// analysed inline and is left queued for the worker: the errors wrapping it
+// tell why.
+var ErrInlineFallback = errors.New("inline analysis fell back to the queue")
+
+// ErrInlineBusy is returned by InlineRunner.Run, without the job having
+// started, when the process or the tenant has no inline slot free.
+var ErrInlineBusy = fmt.Errorf("%w: no inline slot is free", ErrInlineFallback)
+
+// inlineCleanupTimeout bounds the undoing of a job handed back to the
+// queue, once the inline deadline has passed.
+const inlineCleanupTimeout = 10 * time.Second
+
+// InlineConfig bounds the analyses run inline, within the request creating
+// them, by the API.
+type InlineConfig struct {
+	MaxBytes      int64         // Largest file analysed inline
+	Timeout       time.Duration // Deadline of one inline analysis
+	MaxConcurrent int           // Inline analyses running at once in the process
+	MaxPerTenant  int           // Inline analyses of one tenant running at once
+}
+
+// InlineRunner runs the pipeline of small jobs within the API process, so
+// that a snippet uploaded to be searched does not wait for the queue. It
+// holds few slots, so that JAR runs cannot starve the process, and hands a
+// job back to the queue when it overruns its deadline or turns out larger
+// than its file size promised.
+type InlineRunner struct {
+	pipeline *Pipeline
+	cfg      InlineConfig
+
+	mu      sync.Mutex
+	running int
+	tenants map[uuid.UUID]int
+}
+
+// NewInlineRunner creates an InlineRunner of pipeline, whose JAR runner
+// should have a heap and timeout fit for small files. MaxConcurrent and
+// MaxPerTenant <= 0 are treated as 1.
+func NewInlineRunner(pipeline *Pipeline, cfg InlineConfig) *InlineRunner {
+	cfg.MaxConcurrent = max(cfg.MaxConcurrent, 1)
+	cfg.MaxPerTenant = max(cfg.MaxPerTenant, 1)
+	return &InlineRunner{pipeline: pipeline, cfg: cfg, tenants: make(map[uuid.UUID]int)}
+}
+
+// Eligible reports whether a file of sizeBytes is small enough to be
+// analysed inline.
+func (r *InlineRunner) Eligible(sizeBytes int64) bool {
+	return r.cfg.MaxBytes > 0 && sizeBytes <= r.cfg.MaxBytes
+}
+
+// Run analyses job inline, returning once it completed or failed. A job
+// that cannot run inline is left queued, and Run returns an error wrapping
+// ErrInlineFallback: the caller then submits it to the worker. The analysis
+// is not cancelled with ctx, only bounded by the inline deadline, so that a
+// client hanging up does not leave the job half done.
+func (r *InlineRunner) Run(ctx context.Context, job domain.AnalysisJob) error {
+	if !r.acquire(job.TenantID) {
+		return ErrInlineBusy
+	}
+	defer r.release(job.TenantID)
+
+	base := context.WithoutCancel(ctx)
+	runCtx, cancel := context.WithTimeoutCause(base, r.cfg.Timeout,
+		fmt.Errorf("%w: deadline of %s exceeded", ErrInlineFallback, r.cfg.Timeout))
+	defer cancel()
+	runCtx, abort := context.WithCancelCause(runCtx)
+	defer abort(nil)
+	runCtx = context.WithValue(runCtx, inlineKey{}, &inlineRun{maxBytes: r.cfg.MaxBytes, abort: abort})
+
+	err := r.pipeline.ProcessJob(runCtx, job)
+	if err == nil {
+		return nil
+	}
+	fallback := inlineAborted(runCtx)
+	if fallback == nil {
+		return err
+	}
+	r.requeue(base, job, fallback)
+	return fallback
+}
+
+// requeue undoes what the aborted inline analysis of job stored and puts it
+// back in the queued state the worker expects.
+func (r *InlineRunner) requeue(ctx context.Context, job domain.AnalysisJob, reason error) {
+	tenantID := job.TenantID.String()
+	ctx, cancel := context.WithTimeout(storage.WithPrimary(storage.WithTenant(ctx, tenantID)), inlineCleanupTimeout)
+	defer cancel()
+	logger := slog.With("job_id", job.ID.String(), "tenant_id", tenantID)
+	logger.Info("inline analysis handed to the queue", "reason", reason)
+
+	if err := r.pipeline.ch.DeleteJobEntries(ctx, tenantID, job.ID.String()); err != nil {
+		logger.Warn("failed to delete entries of aborted inline analysis", "error", err)
+	}
+	if err := r.pipeline.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusQueued, nil); err != nil {
+		if errors.Is(err, storage.ErrJobCancelled) {
+			// Cancelled meanwhile: the worker skips it on receipt.
+			r.pipeline.cancelJob(ctx, job)
+			return
+		}
+		logger.Warn("failed to requeue aborted inline analysis", "error", err)
+	}
+	r.pipeline.recordEvent(job, domain.JobEventRetry, "inline", "inline analysis handed to the queue",
+		map[string]any{"reason": reason.Error()})
+}
+
+// acquire reserves an inline slot of the tenant, reporting whether one was
+// free.
+func (r *InlineRunner) acquire(tenantID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running >= r.cfg.MaxConcurrent || r.tenants[tenantID] >= r.cfg.MaxPerTenant {
+		return false
+	}
+	r.running++
+	r.tenants[tenantID]++
+	return true
+}
+
+func (r *InlineRunner) release(tenantID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running--
+	if r.tenants[tenantID]--; r.tenants[tenantID] == 0 {
+		delete(r.tenants, tenantID)
+	}
+}
+
+type inlineKey struct{}
+
+// inlineRun is carried by the context of an inline analysis: its stages
+// abort it when the file proves larger than the inline limit.
+type inlineRun struct {
+	maxBytes int64
+	abort    context.CancelCauseFunc
+}
+
+// inlineTooLarge aborts the inline analysis of ctx when its file of
+// sizeBytes is over the inline limit, reporting whether it did. It is
+// false outside inline analyses.
+func inlineTooLarge(ctx context.Context, sizeBytes int64) bool {
+	run, _ := ctx.Value(inlineKey{}).(*inlineRun)
+	if run == nil || sizeBytes <= run.maxBytes {
+		return false
+	}
+	run.abort(fmt.Errorf("%w: file of %d bytes is over the inline limit of %d", ErrInlineFallback, sizeBytes, run.maxBytes))
+	return true
+}
+
+// inlineAborted returns why the inline analysis of ctx was aborted, or nil
+// while it runs and outside inline analyses. The stages of an aborted
+// analysis fail with the context's error; it is reported instead.
+func inlineAborted(ctx context.Context) error {
+	if run, _ := ctx.Value(inlineKey{}).(*inlineRun); run == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrInlineFallback) {
+		return cause
+	}
+	return nil
+}
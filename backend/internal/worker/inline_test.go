@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// inlineTest is a pipeline run inline on a file of content, with the
+// expectations of the stages up to the JAR set.
+type inlineTest struct {
+	pg   *testutil.MockPostgresStore
+	ch   *testutil.MockClickHouseStore
+	jar  *MockJARRunner
+	job  domain.AnalysisJob
+	pipe *Pipeline
+}
+
+func newInlineTest(content string) *inlineTest {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusParsing, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+		Return(nil).Maybe()
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil).Maybe()
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).
+		Return(&domain.LogFile{ID: job.FileID, TenantID: job.TenantID, S3Key: "logs/test.log", SizeBytes: 16}, nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader(content)), nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil).Maybe()
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil).Maybe()
+
+	return &inlineTest{pg: pg, ch: ch, jar: jarRunner, job: job, pipe: NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)}
+}
+
+// expectRequeue expects the aborted analysis to be undone and queued.
+func (tt *inlineTest) expectRequeue() {
+	tt.ch.On("DeleteJobEntries", mock.Anything, tt.job.TenantID.String(), tt.job.ID.String()).Return(nil).Once()
+	tt.pg.On("UpdateJobStatus", mock.Anything, tt.job.TenantID, tt.job.ID, domain.JobStatusQueued, (*string)(nil)).Return(nil).Once()
+}
+
+func TestInlineRunner_DeadlineFallsBackToQueue(t *testing.T) {
+	tt := newInlineTest("sample log content")
+	tt.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), tt.job.JARFlags, tt.job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(nil, context.DeadlineExceeded)
+	tt.expectRequeue()
+
+	r := NewInlineRunner(tt.pipe, InlineConfig{MaxBytes: 1 << 20, Timeout: 50 * time.Millisecond})
+	started := time.Now()
+	err := r.Run(context.Background(), tt.job)
+
+	require.ErrorIs(t, err, ErrInlineFallback)
+	assert.Contains(t, err.Error(), "deadline")
+	assert.Less(t, time.Since(started), 5*time.Second)
+	tt.pg.AssertNotCalled(t, "UpdateJobStatus", mock.Anything, tt.job.TenantID, tt.job.ID, domain.JobStatusFailed, mock.Anything)
+	tt.pg.AssertExpectations(t)
+	tt.ch.AssertExpectations(t)
+}
+
+func TestInlineRunner_LargerFileFallsBackToQueue(t *testing.T) {
+	tt := newInlineTest(strings.Repeat("x", 2048))
+	tt.expectRequeue()
+
+	r := NewInlineRunner(tt.pipe, InlineConfig{MaxBytes: 1024, Timeout: time.Minute})
+	err := r.Run(context.Background(), tt.job)
+
+	require.ErrorIs(t, err, ErrInlineFallback)
+	assert.Contains(t, err.Error(), "over the inline limit")
+	tt.jar.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	tt.pg.AssertExpectations(t)
+	tt.ch.AssertExpectations(t)
+}
+
+func TestInlineRunner_FailedJobIsNotQueued(t *testing.T) {
+	tt := newInlineTest("sample log content")
+	tt.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), tt.job.JARFlags, tt.job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(nil, errors.New("exit code 1"))
+	tt.pg.On("UpdateJobStatus", mock.Anything, tt.job.TenantID, tt.job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+
+	r := NewInlineRunner(tt.pipe, InlineConfig{MaxBytes: 1 << 20, Timeout: time.Minute})
+	err := r.Run(context.Background(), tt.job)
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInlineFallback)
+	tt.ch.AssertNotCalled(t, "DeleteJobEntries", mock.Anything, mock.Anything, mock.Anything)
+	tt.pg.AssertExpectations(t)
+}
+
+func TestInlineRunner_Completes(t *testing.T) {
+	tt := newInlineTest("sample log content")
+	tt.jar.On("Run", mock.Anything, mock.AnythingOfType("string"), tt.job.JARFlags, tt.job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: validJAROutput}, nil)
+	tt.pg.On("UpdateJobResources", mock.Anything, tt.job.TenantID, tt.job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil).Maybe()
+	tt.pg.On("UpdateJobStatus", mock.Anything, tt.job.TenantID, tt.job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	tt.pg.On("UpdateJobSectionPresence", mock.Anything, tt.job.TenantID, tt.job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil)
+	tt.pg.On("GetTenant", mock.Anything, tt.job.TenantID).Return(&domain.Tenant{ID: tt.job.TenantID}, nil).Maybe()
+	tt.pg.On("UpdateJobStatus", mock.Anything, tt.job.TenantID, tt.job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
+	tt.pg.On("UpdateJobProgress", mock.Anything, tt.job.TenantID, tt.job.ID, 100, mock.AnythingOfType("*int64")).Return(nil)
+	expectReconciliation(tt.pg, tt.ch, tt.job, validJARCounts)
+
+	r := NewInlineRunner(tt.pipe, InlineConfig{MaxBytes: 1 << 20, Timeout: time.Minute})
+	require.NoError(t, r.Run(context.Background(), tt.job))
+	tt.pg.AssertExpectations(t)
+	assert.Zero(t, r.running, "the slot is released")
+}
+
+func TestInlineRunner_Slots(t *testing.T) {
+	r := NewInlineRunner(nil, InlineConfig{MaxBytes: 1024, MaxConcurrent: 2, MaxPerTenant: 1})
+	job := newTestJob()
+
+	assert.True(t, r.Eligible(1024))
+	assert.False(t, r.Eligible(1025))
+
+	require.True(t, r.acquire(job.TenantID))
+	assert.ErrorIs(t, r.Run(context.Background(), job), ErrInlineBusy, "the tenant is at its cap")
+
+	other := newTestJob()
+	require.True(t, r.acquire(other.TenantID))
+	assert.ErrorIs(t, r.Run(context.Background(), newTestJob()), ErrInlineBusy, "the process is at its cap")
+
+	r.release(job.TenantID)
+	r.release(other.TenantID)
+	assert.Zero(t, r.running)
+	assert.Empty(t, r.tenants)
+}
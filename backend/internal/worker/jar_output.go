@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// JAROutputKey returns the object storage key of the raw JAR text report
+// kept for an analysis.
+func JAROutputKey(tenantID, jobID string) string {
+	return path.Join("tenants", tenantID, "jar-output", jobID+".report")
+}
+
+// SetStoreJAROutput keeps the JAR's text report of every job in object
+// storage, from where admins capture parser regression fixtures.
+func (p *Pipeline) SetStoreJAROutput(enabled bool) {
+	p.storeJAROutput = enabled
+}
+
+// storeJAROutputReport uploads the text report of job. Failing to keep it
+// does not fail the job.
+func (p *Pipeline) storeJAROutputReport(ctx context.Context, job domain.AnalysisJob, report string, logger *slog.Logger) {
+	key := JAROutputKey(job.TenantID.String(), job.ID.String())
+	if err := p.s3.Upload(ctx, key, strings.NewReader(report), int64(len(report))); err != nil {
+		logger.Warn("failed to store JAR output", "s3_key", key, "error", err)
+		p.recordEvent(job, domain.JobEventWarning, "jar", "failed to store JAR output", map[string]any{"error": err.Error()})
+	}
+}
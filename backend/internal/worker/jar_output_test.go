@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestJAROutputKey(t *testing.T) {
+	assert.Equal(t, "tenants/t1/jar-output/j1.report", JAROutputKey("t1", "j1"))
+}
+
+func TestPipeline_StoreJAROutputReport(t *testing.T) {
+	job := newTestJob()
+	report := "=== General Statistics ===\n"
+	key := JAROutputKey(job.TenantID.String(), job.ID.String())
+
+	t.Run("uploads the report", func(t *testing.T) {
+		s3 := &testutil.MockObjectStorage{}
+		s3.On("Upload", mock.Anything, key, mock.MatchedBy(func(r io.Reader) bool {
+			b, _ := io.ReadAll(r)
+			return string(b) == report
+		}), int64(len(report))).Return(nil)
+
+		p := NewPipeline(nil, nil, s3, nil, nil, nil, nil)
+		p.storeJAROutputReport(context.Background(), job, report, slog.Default())
+		s3.AssertExpectations(t)
+	})
+
+	t.Run("upload failure is not fatal", func(t *testing.T) {
+		s3 := &testutil.MockObjectStorage{}
+		s3.On("Upload", mock.Anything, key, mock.Anything, int64(len(report))).Return(errors.New("s3 unavailable"))
+
+		p := NewPipeline(nil, nil, s3, nil, nil, nil, nil)
+		assert.NotPanics(t, func() {
+			p.storeJAROutputReport(context.Background(), job, report, slog.Default())
+		})
+		s3.AssertExpectations(t)
+	})
+}
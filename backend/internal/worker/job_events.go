@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+)
+
+// SetJobEvents enables the event log of processed jobs.
+func (p *Pipeline) SetJobEvents(a *jobevents.Appender) {
+	p.events = a
+}
+
+// JobEventsURL is the API path of the event timeline of a job.
+func JobEventsURL(jobID uuid.UUID) string {
+	return "/api/v1/analysis/" + jobID.String() + "/events"
+}
+
+// recordEvent appends an event to the job's log. It is a no-op without an
+// event log.
+func (p *Pipeline) recordEvent(job domain.AnalysisJob, kind domain.JobEventKind, stage, message string, metadata map[string]any) {
+	p.events.Append(domain.JobEvent{
+		TenantID: job.TenantID,
+		JobID:    job.ID,
+		Kind:     kind,
+		Stage:    stage,
+		Message:  message,
+		Metadata: metadata,
+	})
+}
+
+// startStage records a pipeline stage starting and returns the function
+// recording it finishing, with its duration and the given metadata. A stage
+// that fails the job is left unfinished in the log.
+func (p *Pipeline) startStage(job domain.AnalysisJob, stage string) func(metadata map[string]any) {
+	p.recordEvent(job, domain.JobEventStageStarted, stage, "", nil)
+	started := time.Now()
+	return func(metadata map[string]any) {
+		if metadata == nil {
+			metadata = make(map[string]any, 1)
+		}
+		metadata["duration_ms"] = time.Since(started).Milliseconds()
+		p.recordEvent(job, domain.JobEventStageFinished, stage, "", metadata)
+	}
+}
+
+// publishProgress publishes a progress milestone and records it, with the
+// publish error if there was one. Per-line progress updates of the JAR are
+// published directly and not recorded.
+func (p *Pipeline) publishProgress(ctx context.Context, job domain.AnalysisJob, pct int, status domain.JobStatus, message string) {
+	metadata := map[string]any{"progress_pct": pct, "status": string(status)}
+	if err := p.nats.PublishJobProgress(ctx, job.TenantID.String(), job.ID.String(), pct, string(status), message); err != nil {
+		metadata["publish_error"] = err.Error()
+	}
+	p.recordEvent(job, domain.JobEventProgress, "", message, metadata)
+}
+
+// publishComplete publishes the job_complete message, pointing it at the
+// job's event log when there is one, and records whether it was delivered.
+func (p *Pipeline) publishComplete(ctx context.Context, job domain.AnalysisJob) {
+	if p.events != nil {
+		job.EventsURL = JobEventsURL(job.ID)
+	}
+	metadata := map[string]any{"subject": "job_complete", "status": string(job.Status)}
+	if err := p.nats.PublishJobComplete(ctx, job.TenantID.String(), job.ID.String(), job); err != nil {
+		metadata["error"] = err.Error()
+		p.recordEvent(job, domain.JobEventPublish, "", "job_complete publish failed", metadata)
+		return
+	}
+	p.recordEvent(job, domain.JobEventPublish, "", "job_complete published", metadata)
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// TestProcessJob_RecordsEventLog runs a job whose JAR fails and replays
+// its event log: the stages it went through in order, the milestones
+// published, the job_complete publish pointing at the log and the failure.
+func TestProcessJob_RecordsEventLog(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	file := &domain.LogFile{ID: job.FileID, TenantID: job.TenantID, S3Key: "logs/test.log", SizeBytes: 1024}
+
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, mock.Anything, mock.Anything).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
+	pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.Anything).Return(nil)
+	pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.Anything).Return(nil)
+	pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.Anything).Return(nil)
+	s3.On("Download", mock.Anything, "logs/test.log").Return(io.NopCloser(strings.NewReader("sample log content")), nil)
+	jarRunner.On("Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&jar.Result{Stderr: "OutOfMemoryError"}, errors.New("exit code 1"))
+	nats.On("PublishJobProgress", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	var published domain.AnalysisJob
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything).
+		Run(func(args mock.Arguments) { published = args.Get(3).(domain.AnalysisJob) }).
+		Return(errors.New("nats: timeout"))
+
+	var mu sync.Mutex
+	var events []domain.JobEvent
+	pg.On("AppendJobEvents", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, args.Get(1).([]domain.JobEvent)...)
+		}).
+		Return(0, nil)
+
+	appender := jobevents.NewAppender(pg, domain.JobEventSourceWorker, 0, 0, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go appender.Run(ctx)
+
+	p := NewPipeline(pg, nil, s3, nil, nats, jarRunner, nil)
+	p.SetJobEvents(appender)
+	require.Error(t, p.ProcessJob(context.Background(), job))
+
+	// The failure flushes the log without waiting for the interval.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) > 0 && events[len(events)-1].Kind == domain.JobEventFailed
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got []string
+	for _, e := range events {
+		assert.Equal(t, job.ID, e.JobID)
+		assert.Equal(t, domain.JobEventSourceWorker, e.Source)
+		got = append(got, string(e.Kind)+":"+e.Stage)
+	}
+	assert.Equal(t, []string{
+		"progress:",
+		"stage_started:download", "stage_finished:download",
+		"stage_started:preflight", "stage_finished:preflight",
+		"progress:",
+		"stage_started:jar",
+		"progress:",
+		"publish:",
+		"failed:",
+	}, got)
+	assert.Equal(t, "nats: timeout", events[8].Metadata["error"], "publish failures are recorded")
+	assert.Equal(t, JobEventsURL(job.ID), published.EventsURL)
+}
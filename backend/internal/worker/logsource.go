@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/logparser"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/logsource"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// sourceBatchSize is the number of entries of a non-AR log inserted at once.
+const sourceBatchSize = 5000
+
+// processSourceJob ingests a downloaded non-AR log, such as a Tomcat access
+// log or a JVM GC log, through its log source. The JAR does not run: the
+// entries are parsed, stored and rolled up, and the dashboard is built from
+// the stored entries.
+func (p *Pipeline) processSourceJob(ctx context.Context, job domain.AnalysisJob, file *domain.LogFile, path string, logger *slog.Logger) error {
+	tenantID := job.TenantID.String()
+	jobID := job.ID.String()
+	source, ok := logsource.Lookup(file.SourceType)
+	if !ok {
+		return p.failJob(ctx, job, fmt.Sprintf("unsupported log source: %s", file.SourceType))
+	}
+
+	if err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusStoring, nil); err != nil {
+		if errors.Is(err, storage.ErrJobCancelled) {
+			return ErrJobCancelled
+		}
+		logger.Error("failed to update status to storing", "error", err)
+		p.recordEvent(job, domain.JobEventWarning, "", "status update to storing failed", map[string]any{"error": err.Error()})
+	}
+	p.publishProgress(ctx, job, 15, domain.JobStatusStoring, "parsing "+string(file.SourceType)+" log")
+	finishIngest := p.startStage(job, "ingest")
+
+	f, err := os.Open(path)
+	if err != nil {
+		return p.failJob(ctx, job, "open log file: "+err.Error())
+	}
+	defer f.Close()
+
+	retention := p.tenantRetentionClass(ctx, job.TenantID)
+	ingestedAt := domain.NewTimestamp(p.now())
+	ingested := make(map[domain.LogType]int64)
+	var (
+		count     int64
+		insertErr error
+	)
+	batch := make([]domain.LogEntry, 0, sourceBatchSize)
+	flush := func() {
+		if len(batch) == 0 || insertErr != nil {
+			batch = batch[:0]
+			return
+		}
+		if err := jobCancelled(ctx); err != nil {
+			insertErr = err
+			batch = batch[:0]
+			return
+		}
+		stampRetentionClass(batch, retention)
+		if err := p.ch.BatchInsertEntries(ctx, batch); err != nil {
+			insertErr = err
+		} else {
+			for i := range batch {
+				ingested[batch[i].LogType]++
+			}
+		}
+		batch = batch[:0]
+	}
+	parseErr := source.Parse(f, func(e domain.LogEntry) {
+		e.TenantID, e.JobID = tenantID, jobID
+		e.FileNumber = 1
+		e.EntryID = logparser.EntryID(jobID, e.FileNumber, e.LineNumber, e.RawText)
+		e.IngestedAt = ingestedAt
+		batch = append(batch, e)
+		count++
+		if len(batch) == sourceBatchSize {
+			flush()
+		}
+	})
+	flush()
+	switch {
+	case parseErr != nil:
+		return p.failJob(ctx, job, "parse log file: "+parseErr.Error())
+	case insertErr != nil:
+		return p.failJob(ctx, job, "store log entries: "+insertErr.Error())
+	case count == 0:
+		return p.failJob(ctx, job, fmt.Sprintf("no %s entries found in the log file", file.SourceType))
+	}
+	logger.Info("log source ingestion complete", "source_type", file.SourceType, "entries_inserted", count)
+
+	if err := p.ch.BuildJobRollup(ctx, tenantID, jobID); err != nil {
+		logger.Warn("minute rollup failed (non-fatal)", "error", err)
+		p.recordEvent(job, domain.JobEventWarning, "ingest", "minute rollup failed", map[string]any{"error": err.Error()})
+	}
+	finishIngest(map[string]any{"entries_parsed": count, "source_type": string(file.SourceType)})
+	p.publishProgress(ctx, job, 90, domain.JobStatusStoring, "log entries indexed")
+
+	// The dashboard is read back from the stored entries, the only report
+	// there is without the JAR.
+	dashboard, err := p.ch.GetDashboardData(ctx, tenantID, jobID, 25)
+	if err != nil {
+		return p.failJob(ctx, job, "build dashboard: "+err.Error())
+	}
+	if p.redis != nil {
+		key := p.redis.TenantKey(tenantID, "dashboard", jobID)
+		if err := p.redis.Set(ctx, key, dashboard, p.dashboardCacheTTL()); err != nil {
+			logger.Warn("redis cache set failed", "section", "dashboard", "error", err)
+		}
+	}
+
+	now := p.now()
+	if err := p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusComplete, nil); err != nil {
+		if errors.Is(err, storage.ErrJobCancelled) {
+			return ErrJobCancelled
+		}
+		errMsg := fmt.Sprintf("failed to update job to complete status: %v", err)
+		_ = p.pg.UpdateJobStatus(ctx, job.TenantID, job.ID, domain.JobStatusFailed, &errMsg)
+		p.publishProgress(ctx, job, 0, domain.JobStatusFailed, errMsg)
+		p.recordEvent(job, domain.JobEventFailed, "", errMsg, nil)
+		return fmt.Errorf("update status to complete: %w", err)
+	}
+	if err := p.pg.UpdateJobProgress(ctx, job.TenantID, job.ID, 100, &count); err != nil {
+		logger.Error("failed to update job progress to 100%%", "error", err)
+	}
+
+	job.Status = domain.JobStatusComplete
+	job.ProgressPct = 100
+	job.CompletedAt = &now
+	job.TotalLines = &count
+	job.LogDuration = &dashboard.GeneralStats.LogDuration
+
+	p.usage.Record(domain.UsageEvent{TenantID: job.TenantID, Metric: domain.UsageAnalysesRun, SourceID: job.ID, Amount: 1, OccurredAt: now})
+	p.usage.Record(domain.UsageEvent{TenantID: job.TenantID, Metric: domain.UsageRowsStored, SourceID: job.ID, Amount: count, OccurredAt: now})
+
+	p.publishComplete(ctx, job)
+	p.publishProgress(ctx, job, 100, domain.JobStatusComplete, "analysis complete")
+	p.recordEvent(job, domain.JobEventCompleted, "", "", map[string]any{"rows_stored": count, "source_type": string(file.SourceType)})
+	logger.Info("job completed", "source_type", file.SourceType, "entries", count, "by_type", ingested)
+	return nil
+}
@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+const gcLog = `[2025-10-10T13:55:00.001+0000][0.012s][info][gc] Using G1
+[2025-10-10T13:55:30.158+0000][30.169s][info][gc] GC(41) Pause Young (Normal) (G1 Evacuation Pause) 512M->128M(2048M) 35.123ms
+[2025-10-10T13:55:38.734+0000][38.745s][info][gc] GC(43) Pause Full (G1 Compaction Pause) 2040M->1900M(2048M) 4234.567ms
+`
+
+// expectSourceJob sets up the mocks a non-AR job runs through up to its
+// entries being parsed.
+func expectSourceJob(pg *testutil.MockPostgresStore, nats *testutil.MockNATSStreamer, s3 *testutil.MockObjectStorage, job domain.AnalysisJob, content string) {
+	file := &domain.LogFile{
+		ID:         job.FileID,
+		TenantID:   job.TenantID,
+		S3Key:      "logs/gc.log",
+		SizeBytes:  int64(len(content)),
+		SourceType: domain.LogSourceJVMGC,
+	}
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusParsing, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
+	s3.On("Download", mock.Anything, "logs/gc.log").Return(io.NopCloser(strings.NewReader(content)), nil)
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusStoring, (*string)(nil)).Return(nil)
+	pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise"}, nil).Maybe()
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+}
+
+func TestProcessJob_LogSourceSkipsJAR(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	redis := &testutil.MockRedisCache{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	expectSourceJob(pg, nats, s3, job, gcLog)
+
+	var stored []domain.LogEntry
+	ch.On("BatchInsertEntries", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		stored = append(stored, args.Get(1).([]domain.LogEntry)...)
+	})
+	ch.On("BuildJobRollup", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil)
+	dashboard := &domain.DashboardData{GeneralStats: domain.GeneralStatistics{TotalLines: 2}}
+	// The dashboard is read back from the primary, which has the entries.
+	ch.On("GetDashboardData", mock.MatchedBy(storage.UsesPrimary), job.TenantID.String(), job.ID.String(), 25).Return(dashboard, nil)
+	cacheKey := "t:" + job.TenantID.String() + ":dashboard:" + job.ID.String()
+	redis.On("TenantKey", job.TenantID.String(), "dashboard", job.ID.String()).Return(cacheKey)
+	redis.On("Set", mock.Anything, cacheKey, dashboard, 24*time.Hour).Return(nil)
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusComplete, (*string)(nil)).Return(nil)
+	pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.MatchedBy(func(n *int64) bool { return *n == 2 })).Return(nil)
+
+	p := NewPipeline(pg, ch, s3, redis, nats, jarRunner, nil)
+	err := p.ProcessJob(context.Background(), job)
+
+	require.NoError(t, err)
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	redis.AssertExpectations(t)
+	jarRunner.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	require.Len(t, stored, 2)
+	for _, e := range stored {
+		assert.Equal(t, domain.LogTypeGC, e.LogType)
+		assert.Equal(t, job.TenantID.String(), e.TenantID)
+		assert.Equal(t, job.ID.String(), e.JobID)
+		assert.NotEmpty(t, e.EntryID)
+		assert.Equal(t, uint16(1), e.FileNumber)
+		assert.Equal(t, domain.RetentionEnterprise, e.RetentionClass)
+	}
+	assert.Equal(t, uint32(2), stored[0].LineNumber)
+	assert.Equal(t, uint32(4235), stored[1].DurationMS)
+}
+
+func TestProcessJob_LogSourceWithoutEntriesFails(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	job := newTestJob()
+	expectSourceJob(pg, nats, s3, job, "not a gc log\n")
+	pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.AnythingOfType("*string")).Return(nil)
+
+	p := NewPipeline(pg, ch, s3, nil, nats, &MockJARRunner{}, nil)
+	err := p.ProcessJob(context.Background(), job)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no jvm_gc entries")
+	ch.AssertNotCalled(t, "BatchInsertEntries", mock.Anything, mock.Anything)
+	pg.AssertExpectations(t)
+}
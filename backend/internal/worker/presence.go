@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// presenceEntry ties one log type to its entry in a SectionPresence.
+type presenceEntry struct {
+	logType domain.LogType
+	label   string
+	p       *domain.LogTypePresence
+}
+
+func presenceEntries(sp *domain.SectionPresence) []presenceEntry {
+	return []presenceEntry{
+		{domain.LogTypeAPI, "API", &sp.API},
+		{domain.LogTypeSQL, "SQL", &sp.SQL},
+		{domain.LogTypeFilter, "filter", &sp.Filter},
+		{domain.LogTypeEscalation, "escalation", &sp.Escalation},
+	}
+}
+
+// reconcileSectionPresence settles presence on the entries actually
+// ingested, which are what the dashboard queries read. A log type whose JAR
+// count and ingested count disagree on being zero gets a warning.
+func reconcileSectionPresence(sp *domain.SectionPresence, ingested map[domain.LogType]int64) {
+	sp.Warnings = nil
+	for _, e := range presenceEntries(sp) {
+		n := ingested[e.logType]
+		e.p.IngestedCount = &n
+		switch {
+		case e.p.JARCount == 0 && n > 0:
+			sp.Warnings = append(sp.Warnings,
+				fmt.Sprintf("JAR reported no %s entries but %d were ingested", e.label, n))
+		case e.p.JARCount > 0 && n == 0:
+			sp.Warnings = append(sp.Warnings,
+				fmt.Sprintf("JAR reported %d %s entries but none were ingested", e.p.JARCount, e.label))
+		}
+		e.p.Present = n > 0
+	}
+}
+
+// sectionLogTypes lists, per cached dashboard section, the log types whose
+// entries it is built from. A section none of whose log types are present
+// holds no rows and is not cached; its endpoint then answers with the empty
+// section computed from the dashboard.
+var sectionLogTypes = map[string][]domain.LogType{
+	"agg":     {domain.LogTypeAPI, domain.LogTypeSQL, domain.LogTypeEscalation},
+	"exc":     {domain.LogTypeAPI, domain.LogTypeSQL},
+	"filters": {domain.LogTypeFilter},
+}
+
+// sectionPresent reports whether a dashboard section may hold rows. Sections
+// not tied to particular log types, and jobs without presence data, always
+// may.
+func sectionPresent(sp *domain.SectionPresence, section string) bool {
+	types, ok := sectionLogTypes[section]
+	if sp == nil || !ok {
+		return true
+	}
+	for _, e := range presenceEntries(sp) {
+		for _, t := range types {
+			if e.logType == t && e.p.Present {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dashboardNoData returns the top-N keys of the dashboard, as used by
+// DashboardData.NoData, whose log types the capture does not hold.
+func dashboardNoData(sp *domain.SectionPresence) []string {
+	keys := []string{"api", "sql", "filters", "escalations"}
+	var absent []string
+	for i, e := range presenceEntries(sp) {
+		if !e.p.Present {
+			absent = append(absent, keys[i])
+		}
+	}
+	return absent
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func TestReconcileSectionPresence_AllZero(t *testing.T) {
+	sp := &domain.SectionPresence{}
+	reconcileSectionPresence(sp, map[domain.LogType]int64{})
+
+	assert.False(t, sp.API.Present)
+	assert.Equal(t, int64Ptr(0), sp.Escalation.IngestedCount)
+	assert.Empty(t, sp.Warnings)
+	assert.Equal(t, []string{"api", "sql", "filters", "escalations"}, dashboardNoData(sp))
+	for _, section := range []string{"agg", "exc", "filters"} {
+		assert.False(t, sectionPresent(sp, section), section)
+	}
+	assert.True(t, sectionPresent(sp, "gaps"), "gaps are not tied to a log type")
+}
+
+func TestReconcileSectionPresence_Partial(t *testing.T) {
+	sp := &domain.SectionPresence{
+		API: domain.LogTypePresence{Present: true, JARCount: 251},
+		SQL: domain.LogTypePresence{Present: true, JARCount: 7307},
+	}
+	reconcileSectionPresence(sp, map[domain.LogType]int64{domain.LogTypeAPI: 251, domain.LogTypeSQL: 7300})
+
+	assert.True(t, sp.API.Present)
+	assert.Equal(t, int64Ptr(7300), sp.SQL.IngestedCount)
+	assert.False(t, sp.Filter.Present)
+	assert.Empty(t, sp.Warnings, "differing non-zero counts are not a discrepancy")
+	assert.Equal(t, []string{"filters", "escalations"}, dashboardNoData(sp))
+	assert.True(t, sectionPresent(sp, "agg"))
+	assert.False(t, sectionPresent(sp, "filters"))
+}
+
+func TestReconcileSectionPresence_Discrepancy(t *testing.T) {
+	// The JAR reports no escalations although entries were ingested, and
+	// reports filters of which none were ingested.
+	sp := &domain.SectionPresence{
+		Filter:     domain.LogTypePresence{Present: true, JARCount: 890},
+		Escalation: domain.LogTypePresence{JARCount: 0},
+	}
+	reconcileSectionPresence(sp, map[domain.LogType]int64{domain.LogTypeEscalation: 12})
+
+	assert.True(t, sp.Escalation.Present, "ingested entries win")
+	assert.False(t, sp.Filter.Present)
+	require.Len(t, sp.Warnings, 2)
+	assert.Equal(t, "JAR reported 890 filter entries but none were ingested", sp.Warnings[0])
+	assert.Equal(t, "JAR reported no escalation entries but 12 were ingested", sp.Warnings[1])
+}
+
+func TestSectionPresent_WithoutPresence(t *testing.T) {
+	assert.True(t, sectionPresent(nil, "filters"))
+}
@@ -0,0 +1,243 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// PriorityPolicy decides how queued jobs of different priorities share the
+// worker slots.
+type PriorityPolicy string
+
+const (
+	// PriorityStrict always starts the highest priority job waiting.
+	PriorityStrict PriorityPolicy = "strict"
+	// PriorityWeighted starts jobs in proportion to priorityWeights, so that
+	// a steady stream of interactive jobs does not hold back the others.
+	PriorityWeighted PriorityPolicy = "weighted"
+)
+
+// priorityWeights is the share of starts each priority gets under
+// PriorityWeighted while jobs of every priority are waiting.
+var priorityWeights = map[domain.JobPriority]int{
+	domain.JobPriorityInteractive: 6,
+	domain.JobPriorityNormal:      3,
+	domain.JobPriorityBatch:       1,
+}
+
+// ParsePriorityPolicy returns the policy named s; empty means strict.
+func ParsePriorityPolicy(s string) (PriorityPolicy, error) {
+	switch p := PriorityPolicy(s); p {
+	case "":
+		return PriorityStrict, nil
+	case PriorityStrict, PriorityWeighted:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown job priority policy %q: must be strict or weighted", s)
+	}
+}
+
+// priorityOf returns the priority a job is queued at. Jobs without a known
+// priority are queued as normal.
+func priorityOf(j domain.QueuedJob) domain.JobPriority {
+	if p := j.Priority.OrDefault(); p.Valid() {
+		return p
+	}
+	return domain.JobPriorityNormal
+}
+
+// priorityQueue chooses which waiting job starts next. Jobs that have
+// waited maxWait since submission start first, oldest first, whatever
+// their priority; otherwise the policy picks a priority and the oldest job
+// of that priority starts. It is not safe for concurrent use.
+type priorityQueue struct {
+	policy  PriorityPolicy
+	maxWait time.Duration
+
+	// credit is the smooth weighted round-robin state of PriorityWeighted.
+	credit map[domain.JobPriority]int
+}
+
+func newPriorityQueue(policy PriorityPolicy, maxWait time.Duration) *priorityQueue {
+	return &priorityQueue{
+		policy:  policy,
+		maxWait: maxWait,
+		credit:  make(map[domain.JobPriority]int),
+	}
+}
+
+// next returns the index in waiting of the job to start at now, or -1 if
+// waiting is empty. It does not change the queue; call taken once the job
+// has started.
+func (q *priorityQueue) next(waiting []domain.QueuedJob, now time.Time) int {
+	if len(waiting) == 0 {
+		return -1
+	}
+	if q.maxWait > 0 {
+		if i := oldest(waiting, func(j domain.QueuedJob) bool { return now.Sub(j.CreatedAt) >= q.maxWait }); i >= 0 {
+			return i
+		}
+	}
+	p := q.pick(waiting)
+	return oldest(waiting, func(j domain.QueuedJob) bool { return priorityOf(j) == p })
+}
+
+// pick returns the priority whose job starts next.
+func (q *priorityQueue) pick(waiting []domain.QueuedJob) domain.JobPriority {
+	present := waitingPriorities(waiting)
+	var best domain.JobPriority
+	bestCredit := 0
+	for _, p := range domain.JobPriorities {
+		if !present[p] {
+			continue
+		}
+		if q.policy != PriorityWeighted {
+			return p
+		}
+		if c := q.credit[p] + priorityWeights[p]; best == "" || c > bestCredit {
+			best, bestCredit = p, c
+		}
+	}
+	return best
+}
+
+// taken records that the job at index i of waiting has started.
+func (q *priorityQueue) taken(waiting []domain.QueuedJob, i int) {
+	if q.policy != PriorityWeighted {
+		return
+	}
+	total := 0
+	for p := range waitingPriorities(waiting) {
+		q.credit[p] += priorityWeights[p]
+		total += priorityWeights[p]
+	}
+	q.credit[priorityOf(waiting[i])] -= total
+}
+
+func waitingPriorities(waiting []domain.QueuedJob) map[domain.JobPriority]bool {
+	present := make(map[domain.JobPriority]bool, len(domain.JobPriorities))
+	for _, j := range waiting {
+		present[priorityOf(j)] = true
+	}
+	return present
+}
+
+// oldest returns the index of the earliest submitted job matching keep, or
+// -1 if none does.
+func oldest(jobs []domain.QueuedJob, keep func(domain.QueuedJob) bool) int {
+	best := -1
+	for i, j := range jobs {
+		if keep(j) && (best < 0 || j.CreatedAt.Before(jobs[best].CreatedAt)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// PriorityGate puts the jobs waiting for a Scheduler in priority order.
+// The NATS consumer of each priority blocks in Dispatch with its next job;
+// whenever capacity is free the gate admits the job the priority policy
+// picks among them.
+type PriorityGate struct {
+	sched *Scheduler
+	now   func() time.Time
+
+	mu      sync.Mutex
+	queue   *priorityQueue
+	waiting []domain.QueuedJob
+	// changed is closed and replaced whenever a job leaves the gate.
+	changed chan struct{}
+}
+
+// NewPriorityGate creates a PriorityGate in front of sched. maxWait <= 0
+// disables the anti-starvation rule.
+func NewPriorityGate(sched *Scheduler, policy PriorityPolicy, maxWait time.Duration) *PriorityGate {
+	return &PriorityGate{
+		sched:   sched,
+		now:     time.Now,
+		queue:   newPriorityQueue(policy, maxWait),
+		changed: make(chan struct{}),
+	}
+}
+
+// Dispatch waits until the job is both picked by the priority policy and
+// admitted by the scheduler, then runs fn as Scheduler.Dispatch does.
+func (g *PriorityGate) Dispatch(ctx context.Context, job domain.AnalysisJob, fn func(ctx context.Context, job domain.AnalysisJob)) error {
+	g.mu.Lock()
+	g.waiting = append(g.waiting, domain.QueuedJob{
+		ID:        job.ID,
+		TenantID:  job.TenantID,
+		Priority:  job.Priority,
+		JVMHeapMB: job.JVMHeapMB,
+		CreatedAt: job.CreatedAt,
+	})
+	g.mu.Unlock()
+
+	for {
+		// Taken before the pick so that a release in between wakes us. Every
+		// waiter re-checks on release, as the release may be what makes a
+		// job overdue.
+		capacityFreed := g.sched.capacityChanged()
+
+		g.mu.Lock()
+		var (
+			release func()
+			err     error
+		)
+		if i := g.queue.next(g.waiting, g.now()); i >= 0 && g.waiting[i].ID == job.ID {
+			release, _, err = g.sched.tryAcquire(job.JVMHeapMB)
+			if release != nil {
+				g.queue.taken(g.waiting, i)
+			}
+			if release != nil || err != nil {
+				g.leaveLocked(job.ID)
+			}
+		}
+		gateChanged := g.changed
+		g.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if release != nil {
+			g.sched.start(job, release, fn)
+			return nil
+		}
+
+		select {
+		case <-capacityFreed:
+		case <-gateChanged:
+		case <-ctx.Done():
+			g.mu.Lock()
+			g.leaveLocked(job.ID)
+			g.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// Waiting returns the number of jobs waiting in the gate.
+func (g *PriorityGate) Waiting() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.waiting)
+}
+
+// leaveLocked removes a job from the gate and wakes the other waiters.
+// Callers must hold g.mu.
+func (g *PriorityGate) leaveLocked(id uuid.UUID) {
+	for i, j := range g.waiting {
+		if j.ID == id {
+			g.waiting = append(g.waiting[:i], g.waiting[i+1:]...)
+			break
+		}
+	}
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
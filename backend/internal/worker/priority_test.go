@@ -0,0 +1,276 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var simEpoch = time.Date(2026, 2, 3, 9, 0, 0, 0, time.UTC)
+
+func queuedJob(p domain.JobPriority, createdAt time.Time) domain.QueuedJob {
+	return domain.QueuedJob{ID: uuid.New(), Priority: p, CreatedAt: createdAt}
+}
+
+func TestParsePriorityPolicy(t *testing.T) {
+	for in, want := range map[string]PriorityPolicy{"": PriorityStrict, "strict": PriorityStrict, "weighted": PriorityWeighted} {
+		got, err := ParsePriorityPolicy(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParsePriorityPolicy("fifo")
+	assert.Error(t, err)
+}
+
+func TestPriorityQueue_Next(t *testing.T) {
+	now := simEpoch.Add(time.Hour)
+	batch := queuedJob(domain.JobPriorityBatch, simEpoch)
+	normal := queuedJob(domain.JobPriorityNormal, simEpoch.Add(10*time.Minute))
+	legacy := queuedJob("", simEpoch.Add(5*time.Minute))
+	interactive := queuedJob(domain.JobPriorityInteractive, simEpoch.Add(50*time.Minute))
+	waiting := []domain.QueuedJob{batch, normal, legacy, interactive}
+
+	t.Run("strict takes the highest priority", func(t *testing.T) {
+		q := newPriorityQueue(PriorityStrict, 0)
+		assert.Equal(t, 3, q.next(waiting, now))
+	})
+
+	t.Run("oldest first within a priority", func(t *testing.T) {
+		q := newPriorityQueue(PriorityStrict, 0)
+		assert.Equal(t, 2, q.next(waiting[:3], now), "jobs without a priority are normal")
+	})
+
+	t.Run("overdue jobs go first", func(t *testing.T) {
+		q := newPriorityQueue(PriorityStrict, time.Hour)
+		assert.Equal(t, 0, q.next(waiting, now))
+		q = newPriorityQueue(PriorityStrict, 55*time.Minute)
+		assert.Equal(t, 0, q.next(waiting, now), "the oldest overdue job goes first")
+		q = newPriorityQueue(PriorityStrict, 2*time.Hour)
+		assert.Equal(t, 3, q.next(waiting, now))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, -1, newPriorityQueue(PriorityWeighted, 0).next(nil, now))
+	})
+}
+
+// simJob is a job of the simulated queue, submitted at CreatedAt.
+type simJob struct {
+	domain.QueuedJob
+	runFor time.Duration
+}
+
+// simulateQueue replays arrivals on slots worker slots with q choosing the
+// next job whenever a slot is free, until every job has started or until
+// has passed. It returns when each job started.
+func simulateQueue(q *priorityQueue, arrivals []simJob, slots int, until time.Time) map[uuid.UUID]time.Time {
+	sort.SliceStable(arrivals, func(a, b int) bool { return arrivals[a].CreatedAt.Before(arrivals[b].CreatedAt) })
+	runFor := make(map[uuid.UUID]time.Duration, len(arrivals))
+	for _, a := range arrivals {
+		runFor[a.ID] = a.runFor
+	}
+
+	freeAt := make([]time.Time, slots)
+	for i := range freeAt {
+		freeAt[i] = simEpoch
+	}
+	starts := make(map[uuid.UUID]time.Time)
+	var waiting []domain.QueuedJob
+	for len(arrivals) > 0 || len(waiting) > 0 {
+		sort.Slice(freeAt, func(a, b int) bool { return freeAt[a].Before(freeAt[b]) })
+		now := freeAt[0]
+		if len(waiting) == 0 && arrivals[0].CreatedAt.After(now) {
+			now = arrivals[0].CreatedAt
+		}
+		if now.After(until) {
+			break
+		}
+		for len(arrivals) > 0 && !arrivals[0].CreatedAt.After(now) {
+			waiting = append(waiting, arrivals[0].QueuedJob)
+			arrivals = arrivals[1:]
+		}
+
+		i := q.next(waiting, now)
+		job := waiting[i]
+		starts[job.ID] = now
+		q.taken(waiting, i)
+		waiting = append(waiting[:i], waiting[i+1:]...)
+		freeAt[0] = now.Add(runFor[job.ID])
+	}
+	return starts
+}
+
+// TestPriorityQueue_FairnessSimulation saturates one slot with interactive
+// jobs and checks that a batch job queued first still starts within the
+// max wait, and only thanks to it.
+func TestPriorityQueue_FairnessSimulation(t *testing.T) {
+	const horizon = 3 * time.Hour
+	newArrivals := func() ([]simJob, simJob) {
+		batch := simJob{queuedJob(domain.JobPriorityBatch, simEpoch), 20 * time.Minute}
+		arrivals := []simJob{batch}
+		// Interactive jobs keep arriving past the horizon so that the slot
+		// is never idle within it.
+		for at := time.Duration(0); at < horizon+time.Hour; at += 2 * time.Minute {
+			arrivals = append(arrivals, simJob{queuedJob(domain.JobPriorityInteractive, simEpoch.Add(at)), 2 * time.Minute})
+		}
+		return arrivals, batch
+	}
+
+	t.Run("strict without max wait starves batch jobs", func(t *testing.T) {
+		arrivals, batch := newArrivals()
+		starts := simulateQueue(newPriorityQueue(PriorityStrict, 0), arrivals, 1, simEpoch.Add(horizon))
+		_, started := starts[batch.ID]
+		assert.False(t, started, "interactive jobs keep the slot busy")
+	})
+
+	for _, policy := range []PriorityPolicy{PriorityStrict, PriorityWeighted} {
+		t.Run(string(policy)+" with max wait", func(t *testing.T) {
+			const maxWait = 30 * time.Minute
+			arrivals, batch := newArrivals()
+			starts := simulateQueue(newPriorityQueue(policy, maxWait), arrivals, 1, simEpoch.Add(horizon))
+
+			require.Contains(t, starts, batch.ID)
+			assert.LessOrEqual(t, starts[batch.ID].Sub(batch.CreatedAt), maxWait+2*time.Minute,
+				"the batch job starts at the latest once the job running when it became overdue ends")
+
+			var worst time.Duration
+			for _, a := range arrivals {
+				if start, ok := starts[a.ID]; ok && a.Priority == domain.JobPriorityInteractive {
+					worst = max(worst, start.Sub(a.CreatedAt))
+				}
+			}
+			assert.LessOrEqual(t, worst, batch.runFor+2*time.Minute, "interactive jobs wait at most for the batch job")
+		})
+	}
+}
+
+func TestPriorityQueue_WeightedShares(t *testing.T) {
+	var arrivals []simJob
+	for i := 0; i < 50; i++ {
+		for _, p := range domain.JobPriorities {
+			arrivals = append(arrivals, simJob{queuedJob(p, simEpoch.Add(time.Duration(i)*time.Second)), time.Minute})
+		}
+	}
+	priorities := make(map[uuid.UUID]domain.JobPriority, len(arrivals))
+	for _, a := range arrivals {
+		priorities[a.ID] = a.Priority
+	}
+
+	starts := simulateQueue(newPriorityQueue(PriorityWeighted, 0), arrivals, 1, simEpoch.Add(20*time.Minute-time.Second))
+	require.Len(t, starts, 20)
+	counts := map[domain.JobPriority]int{}
+	for id := range starts {
+		counts[priorities[id]]++
+	}
+	assert.Equal(t, map[domain.JobPriority]int{
+		domain.JobPriorityInteractive: 12,
+		domain.JobPriorityNormal:      6,
+		domain.JobPriorityBatch:       2,
+	}, counts, "starts follow the 6:3:1 weights while every priority is backlogged")
+
+	// The batch job must not wait for the other queues to drain.
+	firstBatch := simEpoch.Add(time.Hour)
+	for id, at := range starts {
+		if priorities[id] == domain.JobPriorityBatch && at.Before(firstBatch) {
+			firstBatch = at
+		}
+	}
+	assert.Less(t, firstBatch.Sub(simEpoch), 10*time.Minute)
+}
+
+// gateOrder dispatches jobs through a gate whose only slot is busy, frees
+// the slot once every job waits in the gate and returns the order in which
+// the jobs ran.
+func gateOrder(t *testing.T, gate *PriorityGate, sched *Scheduler, jobs ...domain.AnalysisJob) []uuid.UUID {
+	t.Helper()
+	release, err := sched.Acquire(context.Background(), 0)
+	require.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		order []uuid.UUID
+		wg    sync.WaitGroup
+	)
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job domain.AnalysisJob) {
+			defer wg.Done()
+			assert.NoError(t, gate.Dispatch(context.Background(), job, func(ctx context.Context, job domain.AnalysisJob) {
+				mu.Lock()
+				order = append(order, job.ID)
+				mu.Unlock()
+			}))
+		}(job)
+	}
+	require.Eventually(t, func() bool { return gate.Waiting() == len(jobs) }, time.Second, time.Millisecond)
+
+	release()
+	wg.Wait()
+	sched.Wait()
+	return order
+}
+
+func newPriorityJob(p domain.JobPriority, createdAt time.Time) domain.AnalysisJob {
+	return domain.AnalysisJob{ID: uuid.New(), TenantID: uuid.New(), Priority: p, CreatedAt: createdAt}
+}
+
+func TestPriorityGate_Dispatch(t *testing.T) {
+	now := simEpoch.Add(time.Hour)
+	batch := newPriorityJob(domain.JobPriorityBatch, simEpoch)
+	normal := newPriorityJob(domain.JobPriorityNormal, simEpoch.Add(time.Minute))
+	interactive := newPriorityJob(domain.JobPriorityInteractive, simEpoch.Add(2*time.Minute))
+
+	t.Run("strict", func(t *testing.T) {
+		sched := NewScheduler(1, 0, 1024)
+		gate := NewPriorityGate(sched, PriorityStrict, 0)
+		gate.now = func() time.Time { return now }
+
+		order := gateOrder(t, gate, sched, batch, normal, interactive)
+		assert.Equal(t, []uuid.UUID{interactive.ID, normal.ID, batch.ID}, order)
+		assert.Zero(t, gate.Waiting())
+	})
+
+	t.Run("max wait", func(t *testing.T) {
+		sched := NewScheduler(1, 0, 1024)
+		gate := NewPriorityGate(sched, PriorityStrict, time.Hour)
+		gate.now = func() time.Time { return now }
+
+		order := gateOrder(t, gate, sched, batch, normal, interactive)
+		assert.Equal(t, []uuid.UUID{batch.ID, interactive.ID, normal.ID}, order)
+	})
+}
+
+func TestPriorityGate_Errors(t *testing.T) {
+	t.Run("heap budget", func(t *testing.T) {
+		gate := NewPriorityGate(NewScheduler(1, 2048, 1024), PriorityStrict, 0)
+		job := newPriorityJob(domain.JobPriorityInteractive, simEpoch)
+		job.JVMHeapMB = 4096
+		err := gate.Dispatch(context.Background(), job, func(context.Context, domain.AnalysisJob) {})
+		assert.ErrorIs(t, err, ErrHeapBudgetExceeded)
+		assert.Zero(t, gate.Waiting())
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		sched := NewScheduler(1, 0, 1024)
+		gate := NewPriorityGate(sched, PriorityStrict, 0)
+		release, err := sched.Acquire(context.Background(), 0)
+		require.NoError(t, err)
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err = gate.Dispatch(ctx, newPriorityJob(domain.JobPriorityBatch, simEpoch), func(context.Context, domain.AnalysisJob) {
+			t.Error("cancelled job must not run")
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Zero(t, gate.Waiting())
+	})
+}
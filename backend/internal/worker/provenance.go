@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// provenance builds the provenance of an export generated from jobs. An
+// export regenerated from another replays its source's requester and
+// generation time, which, with the unchanged query and data, makes the two
+// artifacts identical.
+func (e *Exporter) provenance(ctx context.Context, export domain.SearchExport, jobs ...*domain.AnalysisJob) (*domain.ArtifactProvenance, error) {
+	query, err := canonicalJSON(export.Query)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize query: %w", err)
+	}
+	prov := &domain.ArtifactProvenance{
+		Format:        export.Format,
+		Query:         query,
+		Analyses:      make([]domain.ProvenanceAnalysis, 0, len(jobs)),
+		ParserVersion: ParserVersion,
+		GeneratedBy:   export.UserID,
+		GeneratedAt:   export.CreatedAt.UTC().Truncate(time.Second),
+	}
+	for _, job := range jobs {
+		a := domain.ProvenanceAnalysis{JobID: job.ID}
+		if job.CompletedAt != nil {
+			t := job.CompletedAt.UTC()
+			a.CompletedAt = &t
+		}
+		prov.Analyses = append(prov.Analyses, a)
+	}
+
+	if export.RegeneratedFrom != nil {
+		source, err := e.pg.GetSearchExport(ctx, export.TenantID, *export.RegeneratedFrom)
+		if err != nil {
+			return nil, fmt.Errorf("load source export: %w", err)
+		}
+		if source.Provenance == nil {
+			return nil, fmt.Errorf("source export %s has no provenance", source.ID)
+		}
+		prov.GeneratedBy = source.Provenance.GeneratedBy
+		prov.GeneratedAt = source.Provenance.GeneratedAt.UTC()
+	}
+	return prov, nil
+}
+
+// canonicalJSON re-encodes raw with sorted keys and no insignificant
+// whitespace. Queries read back from a JSONB column are reordered, so an
+// export's query is only comparable with its regeneration's in this form.
+func canonicalJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// purgeBatch bounds how many trashed analyses are purged per run.
+const purgeBatch = 100
+
+// dashboardCacheSuffixes are the suffixes of the dashboard cache keys the
+// ingestion pipeline writes for a job.
+var dashboardCacheSuffixes = []string{
+	"", ":agg", ":exc", ":gaps", ":threads", ":filters", ":queued", ":logging-activity", ":file-metadata",
+}
+
+// JobPurger permanently deletes analyses: their log entries in ClickHouse,
+// cached dashboard sections in Redis, rows in Postgres and uploaded file
+// and export objects in S3. Analyses moved to the trash are purged once
+// their grace period has passed; administrators can purge one right away.
+type JobPurger struct {
+	pg    storage.PostgresStore
+	ch    storage.ClickHouseStore
+	redis storage.RedisCache
+	s3    storage.ObjectStorage
+	grace time.Duration
+}
+
+// NewJobPurger creates a JobPurger. grace is how long analyses stay in the
+// trash.
+func NewJobPurger(pg storage.PostgresStore, ch storage.ClickHouseStore, redis storage.RedisCache, s3 storage.ObjectStorage, grace time.Duration) *JobPurger {
+	return &JobPurger{pg: pg, ch: ch, redis: redis, s3: s3, grace: grace}
+}
+
+// Run purges the analyses that have been in the trash for longer than the
+// grace period and returns how many were purged. An analysis that fails to
+// purge stays in the trash and is retried on the next run.
+func (p *JobPurger) Run(ctx context.Context, now time.Time) (int, error) {
+	jobs, err := p.pg.ListPurgeableJobs(ctx, now.Add(-p.grace), purgeBatch)
+	if err != nil {
+		return 0, fmt.Errorf("list purgeable jobs: %w", err)
+	}
+
+	purged := 0
+	for i := range jobs {
+		if err := p.Purge(ctx, &jobs[i]); err != nil {
+			slog.Warn("failed to purge deleted analysis",
+				"tenant_id", jobs[i].TenantID.String(), "job_id", jobs[i].ID.String(), "error", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Purge permanently deletes one analysis. ClickHouse is cleaned up first so
+// that a failure leaves the analysis in Postgres to retry; the cache and
+// S3 objects are removed on a best-effort basis afterwards.
+func (p *JobPurger) Purge(ctx context.Context, job *domain.AnalysisJob) error {
+	tenantID := job.TenantID.String()
+	jobID := job.ID.String()
+	logger := slog.With("tenant_id", tenantID, "job_id", jobID)
+	ctx = storage.WithTenant(ctx, tenantID)
+
+	if err := p.ch.DeleteJobEntries(ctx, tenantID, jobID); err != nil {
+		return fmt.Errorf("delete log entries: %w", err)
+	}
+
+	if p.redis != nil {
+		prefix := p.redis.TenantKey(tenantID, "dashboard", jobID)
+		for _, suffix := range dashboardCacheSuffixes {
+			if err := p.redis.Delete(ctx, prefix+suffix); err != nil {
+				logger.Warn("failed to delete cached section", "key", prefix+suffix, "error", err)
+			}
+		}
+	}
+
+	objects, err := p.pg.PurgeJob(ctx, job.TenantID, job.ID)
+	if err != nil {
+		return fmt.Errorf("delete job: %w", err)
+	}
+
+	keys := objects.ExportS3Keys
+	if objects.FileS3Key != "" {
+		keys = append(keys, objects.FileS3Key)
+	}
+	keys = append(keys, JAROutputKey(job.TenantID.String(), job.ID.String()),
+		JARCheckpointKey(job.TenantID.String(), job.ID.String()))
+	for _, key := range keys {
+		if err := p.s3.Delete(ctx, key); err != nil {
+			logger.Warn("failed to delete object of purged analysis", "s3_key", key, "error", err)
+		}
+	}
+
+	logger.Info("analysis purged", "objects", len(keys))
+	return nil
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestJobPurger_Run(t *testing.T) {
+	now := time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC)
+	grace := 30 * 24 * time.Hour
+	tenant := uuid.New()
+	purged := domain.AnalysisJob{ID: uuid.New(), TenantID: tenant}
+	failing := domain.AnalysisJob{ID: uuid.New(), TenantID: tenant}
+
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	redis := new(testutil.MockRedisCache)
+	s3 := new(testutil.MockObjectStorage)
+
+	pg.On("ListPurgeableJobs", mock.Anything, now.Add(-grace), purgeBatch).
+		Return([]domain.AnalysisJob{purged, failing}, nil)
+
+	ch.On("DeleteJobEntries", mock.Anything, tenant.String(), purged.ID.String()).Return(nil)
+	redis.On("TenantKey", tenant.String(), "dashboard", purged.ID.String()).Return("remedyiq:" + tenant.String() + ":dashboard:" + purged.ID.String())
+	redis.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	pg.On("PurgeJob", mock.Anything, tenant, purged.ID).Return(&domain.PurgedJob{
+		FileS3Key:    "tenants/t/files/f.log",
+		ExportS3Keys: []string{"tenants/t/exports/e.csv"},
+	}, nil)
+	s3.On("Delete", mock.Anything, "tenants/t/exports/e.csv").Return(nil)
+	s3.On("Delete", mock.Anything, "tenants/t/files/f.log").Return(errors.New("s3 unavailable"))
+	s3.On("Delete", mock.Anything, JAROutputKey(tenant.String(), purged.ID.String())).Return(nil)
+	s3.On("Delete", mock.Anything, JARCheckpointKey(tenant.String(), purged.ID.String())).Return(nil)
+
+	ch.On("DeleteJobEntries", mock.Anything, tenant.String(), failing.ID.String()).Return(errors.New("mutation rejected"))
+
+	n, err := NewJobPurger(pg, ch, redis, s3, grace).Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	s3.AssertExpectations(t)
+	redis.AssertNumberOfCalls(t, "Delete", len(dashboardCacheSuffixes))
+	// An analysis whose entries could not be deleted stays in the trash.
+	pg.AssertNotCalled(t, "PurgeJob", mock.Anything, tenant, failing.ID)
+}
+
+func TestJobPurger_ListFails(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListPurgeableJobs", mock.Anything, mock.Anything, purgeBatch).Return(nil, errors.New("connection refused"))
+
+	_, err := NewJobPurger(pg, new(testutil.MockClickHouseStore), nil, new(testutil.MockObjectStorage), time.Hour).
+		Run(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "list purgeable jobs")
+}
@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+// defaultMSPerMB is the processing rate assumed before any job has
+// completed.
+const defaultMSPerMB = 600
+
+// EstimateQueue works out where each queued job of snap stands and when it
+// is expected to start. It replays the queue with the worker's priority
+// rules: slots free up as the running jobs finish at the historical rate,
+// and each free slot goes to the job the policy picks at that time. Jobs
+// submitted later are not known and so not accounted for. slots is the
+// number of jobs that run in parallel; it is raised to the number of
+// running jobs when more are running.
+func EstimateQueue(snap *domain.JobQueueSnapshot, slots int, policy PriorityPolicy, maxWait time.Duration, now time.Time) map[uuid.UUID]domain.JobQueueEstimate {
+	estimates := make(map[uuid.UUID]domain.JobQueueEstimate, len(snap.Queued))
+	if len(snap.Queued) == 0 {
+		return estimates
+	}
+
+	msPerMB := snap.MSPerMB
+	if msPerMB <= 0 {
+		msPerMB = defaultMSPerMB
+	}
+	duration := func(j domain.QueuedJob) time.Duration {
+		return time.Duration(float64(j.SizeBytes) / (1 << 20) * msPerMB * float64(time.Millisecond))
+	}
+
+	// freeAt holds when each slot is next free, earliest first.
+	var freeAt []time.Time
+	for _, j := range snap.Running {
+		end := now.Add(duration(j))
+		if j.StartedAt != nil {
+			end = j.StartedAt.Add(duration(j))
+		}
+		if end.Before(now) {
+			end = now // Running longer than expected: assume it ends now.
+		}
+		freeAt = append(freeAt, end)
+	}
+	for len(freeAt) < slots || len(freeAt) == 0 {
+		freeAt = append(freeAt, now)
+	}
+	sort.Slice(freeAt, func(a, b int) bool { return freeAt[a].Before(freeAt[b]) })
+
+	waiting := append([]domain.QueuedJob(nil), snap.Queued...)
+	queue := newPriorityQueue(policy, maxWait)
+	for position := 1; len(waiting) > 0; position++ {
+		start := freeAt[0]
+		i := queue.next(waiting, start)
+		job := waiting[i]
+		estimates[job.ID] = domain.JobQueueEstimate{
+			Position:         position,
+			JobsAhead:        position - 1,
+			EstimatedStartAt: start,
+			EstimatedWaitMS:  start.Sub(now).Milliseconds(),
+		}
+		queue.taken(waiting, i)
+		waiting = append(waiting[:i], waiting[i+1:]...)
+
+		freeAt[0] = start.Add(duration(job))
+		sort.Slice(freeAt, func(a, b int) bool { return freeAt[a].Before(freeAt[b]) })
+	}
+	return estimates
+}
+
+// QueueEstimator estimates the queue position and start time of queued
+// jobs from the queue state in Postgres.
+type QueueEstimator struct {
+	pg      storage.PostgresStore
+	slots   int
+	policy  PriorityPolicy
+	maxWait time.Duration
+	now     func() time.Time
+}
+
+// NewQueueEstimator creates a QueueEstimator for workers that together run
+// slots jobs in parallel under the given priority rules.
+func NewQueueEstimator(pg storage.PostgresStore, slots int, policy PriorityPolicy, maxWait time.Duration) *QueueEstimator {
+	return &QueueEstimator{
+		pg:      pg,
+		slots:   slots,
+		policy:  policy,
+		maxWait: maxWait,
+		now:     time.Now,
+	}
+}
+
+// Estimate returns where the job stands in the queue, or nil when it is
+// not queued.
+func (e *QueueEstimator) Estimate(ctx context.Context, jobID uuid.UUID) (*domain.JobQueueEstimate, error) {
+	snap, err := e.pg.GetJobQueueSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("queue estimate: %w", err)
+	}
+	est, ok := EstimateQueue(snap, e.slots, e.policy, e.maxWait, e.now())[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return &est, nil
+}
+
+// EstimateNew returns where a job of a tenant for a file of sizeBytes would
+// stand in the queue if it were submitted now at priority.
+func (e *QueueEstimator) EstimateNew(ctx context.Context, tenantID uuid.UUID, sizeBytes int64, priority domain.JobPriority) (*domain.JobQueueEstimate, error) {
+	snap, err := e.pg.GetJobQueueSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("queue estimate: %w", err)
+	}
+	now := e.now()
+	candidate := domain.QueuedJob{ID: uuid.New(), TenantID: tenantID, Priority: priority.OrDefault(), SizeBytes: sizeBytes, CreatedAt: now}
+	withNew := *snap
+	withNew.Queued = append(append([]domain.QueuedJob(nil), snap.Queued...), candidate)
+	est := EstimateQueue(&withNew, e.slots, e.policy, e.maxWait, now)[candidate.ID]
+	return &est, nil
+}
+
+// PublishQueued sends a job_progress update with the queue estimate of
+// every queued job. Workers call it whenever a job starts, as that moves
+// the whole queue.
+func (e *QueueEstimator) PublishQueued(ctx context.Context, nats streaming.NATSStreamer) error {
+	snap, err := e.pg.GetJobQueueSnapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("queue estimate: %w", err)
+	}
+	estimates := EstimateQueue(snap, e.slots, e.policy, e.maxWait, e.now())
+	for _, j := range snap.Queued {
+		if err := nats.PublishJobQueued(ctx, j.TenantID.String(), j.ID.String(), estimates[j.ID]); err != nil {
+			slog.Warn("failed to publish queue estimate", "job_id", j.ID, "error", err)
+		}
+	}
+	return nil
+}
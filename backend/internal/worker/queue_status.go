@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// BuildQueueStatus summarises the queue activity and the backlog of the job
+// submission stream for operators. Workers whose last heartbeat is older
+// than staleAfter are reported but not counted as alive.
+//
+// The backlog ETA assumes the queued jobs finish at the rate jobs finished
+// since activity.FinishedSince. It is 0 for an empty queue and left out
+// when no job finished or no worker is alive, as the queue is then not
+// moving.
+func BuildQueueStatus(activity *domain.QueueActivity, stream []domain.StreamDepth, now time.Time, staleAfter time.Duration) *domain.QueueStatus {
+	status := &domain.QueueStatus{
+		GeneratedAt:      now,
+		Tenants:          []domain.TenantQueueStatus{},
+		Running:          []domain.RunningJob{},
+		Workers:          []domain.WorkerStatus{},
+		Stream:           stream,
+		FinishedLastHour: activity.FinishedCount,
+	}
+	if status.Stream == nil {
+		status.Stream = []domain.StreamDepth{}
+	}
+
+	tenants := make(map[uuid.UUID]map[domain.JobStatus]domain.JobStatusCount)
+	for _, j := range activity.Jobs {
+		since := j.CreatedAt
+		if j.Status != domain.JobStatusQueued && j.StartedAt != nil {
+			since = *j.StartedAt
+		}
+		age := now.Sub(since).Milliseconds()
+
+		counts := tenants[j.TenantID]
+		if counts == nil {
+			counts = make(map[domain.JobStatus]domain.JobStatusCount)
+			tenants[j.TenantID] = counts
+		}
+		c := counts[j.Status]
+		c.Count++
+		c.OldestAgeMS = max(c.OldestAgeMS, age)
+		counts[j.Status] = c
+
+		if j.Status == domain.JobStatusQueued {
+			status.QueuedJobs++
+			if status.OldestQueued == nil || j.CreatedAt.Before(status.OldestQueued.CreatedAt) {
+				status.OldestQueued = &domain.WaitingJob{
+					JobID:     j.ID,
+					TenantID:  j.TenantID,
+					Priority:  j.Priority,
+					CreatedAt: j.CreatedAt,
+					WaitMS:    age,
+				}
+			}
+			continue
+		}
+		running := domain.RunningJob{
+			JobID:       j.ID,
+			TenantID:    j.TenantID,
+			Status:      j.Status,
+			ProgressPct: j.ProgressPct,
+			StartedAt:   j.StartedAt,
+			RunningMS:   age,
+		}
+		if j.LastEvent != nil {
+			at := j.LastEvent.OccurredAt
+			running.Stage = j.LastEvent.Stage
+			running.LastEventAt = &at
+			running.LastMessage = j.LastEvent.Message
+		}
+		status.Running = append(status.Running, running)
+	}
+	for tenantID, counts := range tenants {
+		status.Tenants = append(status.Tenants, domain.TenantQueueStatus{TenantID: tenantID, Statuses: counts})
+	}
+	sort.Slice(status.Tenants, func(a, b int) bool {
+		return status.Tenants[a].TenantID.String() < status.Tenants[b].TenantID.String()
+	})
+	sort.SliceStable(status.Running, func(a, b int) bool {
+		return status.Running[a].RunningMS > status.Running[b].RunningMS
+	})
+
+	for _, hb := range activity.Workers {
+		alive := now.Sub(hb.LastSeenAt) <= staleAfter
+		if alive {
+			status.LiveWorkers++
+		}
+		status.Workers = append(status.Workers, domain.WorkerStatus{WorkerHeartbeat: hb, Alive: alive})
+	}
+
+	window := now.Sub(activity.FinishedSince)
+	switch {
+	case status.QueuedJobs == 0:
+		var zero int64
+		status.BacklogETAMS = &zero
+	case activity.FinishedCount > 0 && status.LiveWorkers > 0 && window > 0:
+		perJob := float64(window.Milliseconds()) / float64(activity.FinishedCount)
+		eta := int64(perJob * float64(status.QueuedJobs))
+		status.BacklogETAMS = &eta
+	}
+	return status
+}
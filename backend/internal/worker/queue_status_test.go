@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestBuildQueueStatus(t *testing.T) {
+	now := simEpoch.Add(time.Hour)
+	tenantA := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	tenantB := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	startedAt := now.Add(-2 * time.Minute)
+	eventAt := now.Add(-10 * time.Second)
+
+	oldest := domain.ActiveJob{ID: uuid.New(), TenantID: tenantB, Status: domain.JobStatusQueued, Priority: domain.JobPriorityBatch, CreatedAt: now.Add(-30 * time.Minute)}
+	activity := &domain.QueueActivity{
+		Jobs: []domain.ActiveJob{
+			oldest,
+			{ID: uuid.New(), TenantID: tenantA, Status: domain.JobStatusQueued, CreatedAt: now.Add(-5 * time.Minute)},
+			{ID: uuid.New(), TenantID: tenantA, Status: domain.JobStatusQueued, CreatedAt: now.Add(-time.Minute)},
+			{
+				ID: uuid.New(), TenantID: tenantA, Status: domain.JobStatusParsing, ProgressPct: 40,
+				CreatedAt: now.Add(-time.Hour), StartedAt: &startedAt,
+				LastEvent: &domain.JobEvent{Stage: "jar", Message: "parsing 1.2 GB", OccurredAt: eventAt},
+			},
+		},
+		Workers: []domain.WorkerHeartbeat{
+			{WorkerID: "w1", RunningJobs: 1, MaxJobs: 2, LastSeenAt: now.Add(-5 * time.Second)},
+			{WorkerID: "w2", LastSeenAt: now.Add(-10 * time.Minute)},
+		},
+		FinishedSince: now.Add(-time.Hour),
+		FinishedCount: 12,
+	}
+	stream := []domain.StreamDepth{{Consumer: "worker-job-submit-normal", Pending: 3}}
+
+	got := BuildQueueStatus(activity, stream, now, HeartbeatStaleAfter)
+
+	require.Len(t, got.Tenants, 2)
+	assert.Equal(t, tenantA, got.Tenants[0].TenantID)
+	assert.Equal(t, map[domain.JobStatus]domain.JobStatusCount{
+		domain.JobStatusQueued:  {Count: 2, OldestAgeMS: 5 * 60 * 1000},
+		domain.JobStatusParsing: {Count: 1, OldestAgeMS: 2 * 60 * 1000},
+	}, got.Tenants[0].Statuses, "running jobs age from their start")
+
+	require.NotNil(t, got.OldestQueued)
+	assert.Equal(t, oldest.ID, got.OldestQueued.JobID)
+	assert.Equal(t, int64(30*60*1000), got.OldestQueued.WaitMS)
+
+	require.Len(t, got.Running, 1)
+	assert.Equal(t, "jar", got.Running[0].Stage)
+	assert.Equal(t, 40, got.Running[0].ProgressPct)
+	assert.Equal(t, &eventAt, got.Running[0].LastEventAt)
+
+	assert.Equal(t, 1, got.LiveWorkers)
+	assert.True(t, got.Workers[0].Alive)
+	assert.False(t, got.Workers[1].Alive, "a worker silent for 10 minutes is reported dead")
+
+	assert.Equal(t, stream, got.Stream)
+	assert.Equal(t, 3, got.QueuedJobs)
+	require.NotNil(t, got.BacklogETAMS)
+	assert.Equal(t, int64(15*60*1000), *got.BacklogETAMS, "12 jobs an hour clear 3 jobs in 15 minutes")
+}
+
+func TestBuildQueueStatus_EmptyQueue(t *testing.T) {
+	now := simEpoch
+	got := BuildQueueStatus(&domain.QueueActivity{FinishedSince: now.Add(-time.Hour)}, nil, now, HeartbeatStaleAfter)
+
+	assert.Empty(t, got.Tenants)
+	assert.NotNil(t, got.Tenants, "empty lists are encoded as []")
+	assert.NotNil(t, got.Running)
+	assert.NotNil(t, got.Workers)
+	assert.NotNil(t, got.Stream)
+	assert.Nil(t, got.OldestQueued)
+	require.NotNil(t, got.BacklogETAMS)
+	assert.Zero(t, *got.BacklogETAMS)
+}
+
+func TestBuildQueueStatus_NoWorkers(t *testing.T) {
+	now := simEpoch.Add(time.Hour)
+	activity := &domain.QueueActivity{
+		Jobs:          []domain.ActiveJob{{ID: uuid.New(), TenantID: uuid.New(), Status: domain.JobStatusQueued, CreatedAt: now.Add(-time.Minute)}},
+		FinishedSince: now.Add(-time.Hour),
+		FinishedCount: 20,
+	}
+
+	got := BuildQueueStatus(activity, nil, now, HeartbeatStaleAfter)
+
+	assert.Zero(t, got.LiveWorkers)
+	assert.Nil(t, got.BacklogETAMS, "the queue does not move without workers")
+	assert.Equal(t, 1, got.QueuedJobs)
+}
+
+func TestHeartbeat(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	h := NewHeartbeat(pg, "worker-0", 4, func() int { return 3 })
+
+	pg.On("RecordWorkerHeartbeat", mock.Anything, mock.MatchedBy(func(hb *domain.WorkerHeartbeat) bool {
+		return hb.WorkerID == h.WorkerID() && hb.Hostname == "worker-0" && hb.RunningJobs == 3 && hb.MaxJobs == 4
+	})).Return(nil).Once()
+	require.NoError(t, h.Beat(context.Background()))
+
+	pg.On("RecordWorkerHeartbeat", mock.Anything, mock.Anything).Return(errors.New("db down")).Once()
+	assert.ErrorContains(t, h.Beat(context.Background()), "db down")
+
+	pg.On("DeleteWorkerHeartbeat", mock.Anything, h.WorkerID()).Return(nil)
+	require.NoError(t, h.Stop(context.Background()))
+	pg.AssertExpectations(t)
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func sizedJob(p domain.JobPriority, sizeMB int64, createdAt time.Time) domain.QueuedJob {
+	j := queuedJob(p, createdAt)
+	j.SizeBytes = sizeMB << 20
+	return j
+}
+
+func TestEstimateQueue(t *testing.T) {
+	now := simEpoch.Add(time.Hour)
+
+	t.Run("free slots and priority order", func(t *testing.T) {
+		batch := sizedJob(domain.JobPriorityBatch, 2000, simEpoch)
+		normal := sizedJob(domain.JobPriorityNormal, 200, simEpoch.Add(time.Minute))
+		interactive := sizedJob(domain.JobPriorityInteractive, 10, simEpoch.Add(2*time.Minute))
+		snap := &domain.JobQueueSnapshot{
+			Queued:  []domain.QueuedJob{batch, normal, interactive},
+			MSPerMB: 100,
+		}
+
+		got := EstimateQueue(snap, 2, PriorityStrict, 0, now)
+		assert.Equal(t, domain.JobQueueEstimate{Position: 1, EstimatedStartAt: now}, got[interactive.ID])
+		assert.Equal(t, domain.JobQueueEstimate{Position: 2, JobsAhead: 1, EstimatedStartAt: now}, got[normal.ID])
+		assert.Equal(t, domain.JobQueueEstimate{
+			Position:         3,
+			JobsAhead:        2,
+			EstimatedStartAt: now.Add(time.Second),
+			EstimatedWaitMS:  1000,
+		}, got[batch.ID], "the batch job takes the slot of the 10 MB job after 1s")
+	})
+
+	t.Run("running jobs hold the slots", func(t *testing.T) {
+		startedAt := now.Add(-5 * time.Second)
+		running := sizedJob(domain.JobPriorityNormal, 100, simEpoch)
+		running.StartedAt = &startedAt
+		overrun := sizedJob(domain.JobPriorityNormal, 1, simEpoch)
+		overrun.StartedAt = &simEpoch
+		queued := sizedJob(domain.JobPriorityInteractive, 50, simEpoch)
+		next := sizedJob(domain.JobPriorityInteractive, 50, simEpoch.Add(time.Minute))
+		snap := &domain.JobQueueSnapshot{
+			Queued:  []domain.QueuedJob{queued, next},
+			Running: []domain.QueuedJob{running, overrun},
+			MSPerMB: 100,
+		}
+
+		got := EstimateQueue(snap, 1, PriorityStrict, 0, now)
+		assert.Equal(t, now, got[queued.ID].EstimatedStartAt, "a job running past its estimate is assumed to end now")
+		assert.Equal(t, int64(5000), got[next.ID].EstimatedWaitMS, "the 100 MB job has 5s of its 10s left")
+	})
+
+	t.Run("max wait", func(t *testing.T) {
+		batch := sizedJob(domain.JobPriorityBatch, 10, simEpoch)
+		interactive := sizedJob(domain.JobPriorityInteractive, 10, now)
+		snap := &domain.JobQueueSnapshot{Queued: []domain.QueuedJob{batch, interactive}, MSPerMB: 100}
+
+		got := EstimateQueue(snap, 1, PriorityStrict, 30*time.Minute, now)
+		assert.Equal(t, 1, got[batch.ID].Position)
+		assert.Equal(t, 2, got[interactive.ID].Position)
+	})
+
+	t.Run("default rate", func(t *testing.T) {
+		first := sizedJob(domain.JobPriorityNormal, 10, simEpoch)
+		second := sizedJob(domain.JobPriorityNormal, 10, simEpoch.Add(time.Second))
+		snap := &domain.JobQueueSnapshot{Queued: []domain.QueuedJob{first, second}}
+
+		got := EstimateQueue(snap, 0, PriorityStrict, 0, now)
+		assert.Equal(t, int64(10*defaultMSPerMB), got[second.ID].EstimatedWaitMS)
+	})
+
+	t.Run("empty queue", func(t *testing.T) {
+		assert.Empty(t, EstimateQueue(&domain.JobQueueSnapshot{}, 2, PriorityWeighted, 0, now))
+	})
+}
+
+func TestQueueEstimator(t *testing.T) {
+	now := simEpoch.Add(time.Hour)
+	queued := sizedJob(domain.JobPriorityNormal, 10, simEpoch)
+	queued.TenantID = uuid.New()
+	snap := &domain.JobQueueSnapshot{Queued: []domain.QueuedJob{queued}, MSPerMB: 100}
+
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetJobQueueSnapshot", mock.Anything).Return(snap, nil)
+	e := NewQueueEstimator(pg, 2, PriorityStrict, 0)
+	e.now = func() time.Time { return now }
+
+	t.Run("estimate", func(t *testing.T) {
+		est, err := e.Estimate(context.Background(), queued.ID)
+		require.NoError(t, err)
+		require.NotNil(t, est)
+		assert.Equal(t, 1, est.Position)
+
+		est, err = e.Estimate(context.Background(), uuid.New())
+		require.NoError(t, err)
+		assert.Nil(t, est, "jobs that are not queued have no estimate")
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		nats := new(testutil.MockNATSStreamer)
+		nats.On("PublishJobQueued", mock.Anything, queued.TenantID.String(), queued.ID.String(),
+			domain.JobQueueEstimate{Position: 1, EstimatedStartAt: now}).Return(nil)
+		require.NoError(t, e.PublishQueued(context.Background(), nats))
+		nats.AssertExpectations(t)
+	})
+
+	t.Run("new job", func(t *testing.T) {
+		single := NewQueueEstimator(pg, 1, PriorityStrict, 0)
+		single.now = func() time.Time { return now }
+		est, err := single.EstimateNew(context.Background(), uuid.New(), 10<<20, domain.JobPriorityNormal)
+		require.NoError(t, err)
+		assert.Equal(t, 2, est.Position)
+		assert.Equal(t, int64(1000), est.EstimatedWaitMS, "behind the queued job of 10 MB at 100 ms/MB")
+		assert.Len(t, snap.Queued, 1, "the snapshot is left as it was")
+	})
+
+	t.Run("snapshot error", func(t *testing.T) {
+		failing := new(testutil.MockPostgresStore)
+		failing.On("GetJobQueueSnapshot", mock.Anything).Return(nil, errors.New("connection refused"))
+		_, err := NewQueueEstimator(failing, 1, PriorityStrict, 0).Estimate(context.Background(), queued.ID)
+		assert.ErrorContains(t, err, "connection refused")
+	})
+}
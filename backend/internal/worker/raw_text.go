@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"unicode/utf8"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// SetRawTextLimit caps the raw text stored per entry at limit characters
+// for jobs that do not set their own limit. Zero or less keeps the full
+// text.
+func (p *Pipeline) SetRawTextLimit(limit int) {
+	p.rawTextLimit = limit
+}
+
+// jobRawTextLimit returns the raw text limit of job: its own, or the
+// pipeline's when it sets none. Zero means no limit.
+func (p *Pipeline) jobRawTextLimit(job domain.AnalysisJob) int {
+	limit := p.rawTextLimit
+	if job.JARFlags.RawTextLimit != 0 {
+		limit = job.JARFlags.RawTextLimit
+	}
+	return max(limit, 0)
+}
+
+// linePosition locates an entry in the capture. A zero file matches the
+// line in any file: v3 reports do not number the files.
+type linePosition struct {
+	file uint16
+	line uint32
+}
+
+// rawTextTrimmer cuts the raw text of ingested entries short to save
+// storage, flagging the entries it cut. Failed entries and the entries the
+// report's top-N tables point at are the ones read in full, so they keep
+// their text.
+type rawTextTrimmer struct {
+	limit    int
+	preserve map[linePosition]struct{}
+
+	truncated int64
+}
+
+// newRawTextTrimmer returns the trimmer of a job's raw text limit, with the
+// preserve list taken from the top-N tables of its parse result, or nil
+// when the job keeps the full text.
+func newRawTextTrimmer(limit int, result *domain.ParseResult) *rawTextTrimmer {
+	if limit <= 0 {
+		return nil
+	}
+	t := &rawTextTrimmer{limit: limit, preserve: make(map[linePosition]struct{})}
+	if result == nil {
+		return t
+	}
+	tables := [][]domain.TopNEntry{result.QueuedAPICalls}
+	if d := result.Dashboard; d != nil {
+		tables = append(tables, d.TopAPICalls, d.TopSQL, d.TopFilters, d.TopEscalations)
+	}
+	for _, table := range tables {
+		for _, e := range table {
+			if e.LineNumber > 0 {
+				t.preserve[linePosition{file: uint16(max(e.FileNumber, 0)), line: uint32(e.LineNumber)}] = struct{}{}
+			}
+		}
+	}
+	return t
+}
+
+// preserved reports whether e keeps its full raw text.
+func (t *rawTextTrimmer) preserved(e *domain.LogEntry) bool {
+	if !e.Success || e.ErrorEncountered || e.ErrorMessage != "" {
+		return true
+	}
+	if _, ok := t.preserve[linePosition{file: e.FileNumber, line: e.LineNumber}]; ok {
+		return true
+	}
+	_, ok := t.preserve[linePosition{line: e.LineNumber}]
+	return ok
+}
+
+// apply truncates the raw text of the entries of batch in place.
+func (t *rawTextTrimmer) apply(batch []domain.LogEntry) {
+	for i := range batch {
+		e := &batch[i]
+		if len(e.RawText) <= t.limit || t.preserved(e) {
+			continue
+		}
+		if text, cut := truncateRunes(e.RawText, t.limit); cut {
+			e.RawText, e.RawTextTruncated = text, true
+			t.truncated++
+		}
+	}
+}
+
+// truncateRunes returns the first n characters of s and whether any were
+// cut off.
+func truncateRunes(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	i := 0
+	for count := 0; i < len(s) && count < n; count++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i], i < len(s)
+}
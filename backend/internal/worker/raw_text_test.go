@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+		cut  bool
+	}{
+		{"short", 10, "short", false},
+		{"exactly10!", 10, "exactly10!", false},
+		{"SELECT * FROM T001", 6, "SELECT", true},
+		{"Größenänderung", 4, "Größ", true},
+		{"日本語のテキスト", 3, "日本語", true},
+		{"日本語", 3, "日本語", false},
+	}
+	for _, tt := range tests {
+		got, cut := truncateRunes(tt.in, tt.n)
+		assert.Equal(t, tt.want, got, tt.in)
+		assert.Equal(t, tt.cut, cut, tt.in)
+	}
+}
+
+func TestPipeline_JobRawTextLimit(t *testing.T) {
+	p := &Pipeline{}
+	assert.Zero(t, p.jobRawTextLimit(domain.AnalysisJob{}))
+	assert.Equal(t, 100, p.jobRawTextLimit(domain.AnalysisJob{JARFlags: domain.JARFlags{RawTextLimit: 100}}))
+
+	p.SetRawTextLimit(500)
+	assert.Equal(t, 500, p.jobRawTextLimit(domain.AnalysisJob{}))
+	assert.Equal(t, 100, p.jobRawTextLimit(domain.AnalysisJob{JARFlags: domain.JARFlags{RawTextLimit: 100}}))
+	assert.Zero(t, p.jobRawTextLimit(domain.AnalysisJob{JARFlags: domain.JARFlags{RawTextLimit: -1}}), "a job may keep the full text")
+}
+
+func TestRawTextTrimmer_PreserveList(t *testing.T) {
+	assert.Nil(t, newRawTextTrimmer(0, &domain.ParseResult{}))
+
+	result := &domain.ParseResult{
+		Dashboard: &domain.DashboardData{
+			TopAPICalls: []domain.TopNEntry{{LineNumber: 10, FileNumber: 2}},
+			TopSQL:      []domain.TopNEntry{{LineNumber: 20}}, // v3 reports do not number files
+			TopFilters:  []domain.TopNEntry{{LineNumber: 30, FileNumber: 1}},
+		},
+		QueuedAPICalls: []domain.TopNEntry{{LineNumber: 40, FileNumber: 1}},
+	}
+	trimmer := newRawTextTrimmer(8, result)
+	require.NotNil(t, trimmer)
+
+	long := strings.Repeat("x", 50)
+	tests := []struct {
+		name string
+		e    domain.LogEntry
+		keep bool
+	}{
+		{"top API call", domain.LogEntry{FileNumber: 2, LineNumber: 10, Success: true}, true},
+		{"same line of another file", domain.LogEntry{FileNumber: 1, LineNumber: 10, Success: true}, false},
+		{"top SQL of any file", domain.LogEntry{FileNumber: 3, LineNumber: 20, Success: true}, true},
+		{"top filter", domain.LogEntry{FileNumber: 1, LineNumber: 30, Success: true}, true},
+		{"queued API call", domain.LogEntry{FileNumber: 1, LineNumber: 40, Success: true}, true},
+		{"failed", domain.LogEntry{FileNumber: 1, LineNumber: 50, Success: false}, true},
+		{"error encountered", domain.LogEntry{FileNumber: 1, LineNumber: 51, Success: true, ErrorEncountered: true}, true},
+		{"error message", domain.LogEntry{FileNumber: 1, LineNumber: 52, Success: true, ErrorMessage: "ARERR 302"}, true},
+		{"ordinary", domain.LogEntry{FileNumber: 1, LineNumber: 60, Success: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := []domain.LogEntry{tt.e}
+			batch[0].RawText = long
+			trimmer.apply(batch)
+			if tt.keep {
+				assert.Equal(t, long, batch[0].RawText)
+				assert.False(t, batch[0].RawTextTruncated)
+			} else {
+				assert.Equal(t, "xxxxxxxx", batch[0].RawText)
+				assert.True(t, batch[0].RawTextTruncated)
+			}
+		})
+	}
+
+	// Text within the limit is not flagged.
+	batch := []domain.LogEntry{{FileNumber: 1, LineNumber: 60, Success: true, RawText: "short"}}
+	trimmer.apply(batch)
+	assert.False(t, batch[0].RawTextTruncated)
+	assert.Equal(t, int64(2), trimmer.truncated)
+}
+
+// syntheticVerboseJob returns the entries of a job whose SQL lines carry
+// long statements, one in fifty of them failed.
+func syntheticVerboseJob(n int) []domain.LogEntry {
+	entries := make([]domain.LogEntry, n)
+	for i := range entries {
+		stmt := fmt.Sprintf("SELECT T%d.C1,T%d.C2,T%d.C3,T%d.C7,T%d.C8 FROM T%d WHERE ((T%d.C%d = 'REQ%012d') AND (T%d.C7 < %d)) ORDER BY 1 ASC",
+			i%40, i%40, i%40, i%40, i%40, i%40, i%40, 536870913+i%7, i, i%40, i%5)
+		entries[i] = domain.LogEntry{
+			FileNumber:   1,
+			LineNumber:   uint32(i + 1),
+			LogType:      domain.LogTypeSQL,
+			Success:      i%50 != 0,
+			SQLStatement: stmt,
+			RawText: fmt.Sprintf("<SQL > <TrID: %08d> <TID: %010d> <RPC ID: %07d> <Queue: Fast      > <Client-RPC: 390620   > <USER: user%03d > <Overlay-Group: 1         > /* Tue Feb 03 2026 10:%02d:%02d.%04d */ %s",
+				i*7, 1000+i%16, i/3, i%200, (i/60)%60, i%60, i%10000, stmt),
+		}
+	}
+	return entries
+}
+
+// rawTextBytesPerRow measures the raw_text column of entries: its size
+// in bytes per row as written, and compressed with ZSTD level 3 as the
+// column codec stores it.
+func rawTextBytesPerRow(t *testing.T, entries []domain.LogEntry) (raw, compressed float64) {
+	t.Helper()
+	var column []byte
+	for i := range entries {
+		column = append(column, entries[i].RawText...)
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(3)))
+	require.NoError(t, err)
+	defer enc.Close()
+	n := float64(len(entries))
+	return float64(len(column)) / n, float64(len(enc.EncodeAll(column, nil))) / n
+}
+
+// TestRawTextTrimmer_StorageFootprint reports the raw_text bytes per row of
+// a synthetic job before and after truncation. Run it with -v to see them.
+func TestRawTextTrimmer_StorageFootprint(t *testing.T) {
+	const limit = 120
+	entries := syntheticVerboseJob(20000)
+	topSQL := []domain.TopNEntry{{LineNumber: 2, FileNumber: 1}, {LineNumber: 3, FileNumber: 1}}
+
+	beforeRaw, beforeZSTD := rawTextBytesPerRow(t, entries)
+	trimmer := newRawTextTrimmer(limit, &domain.ParseResult{Dashboard: &domain.DashboardData{TopSQL: topSQL}})
+	trimmer.apply(entries)
+	afterRaw, afterZSTD := rawTextBytesPerRow(t, entries)
+
+	t.Logf("raw_text bytes/row over %d rows, limit %d: %.1f -> %.1f written, %.1f -> %.1f ZSTD(3); %d rows truncated",
+		len(entries), limit, beforeRaw, afterRaw, beforeZSTD, afterZSTD, trimmer.truncated)
+	assert.Less(t, afterRaw, beforeRaw)
+	assert.Less(t, afterZSTD, beforeZSTD)
+	assert.Equal(t, int64(len(entries)-len(entries)/50-len(topSQL)), trimmer.truncated,
+		"failed entries and the top-N entries keep their text")
+	assert.False(t, entries[0].RawTextTruncated, "the first entry failed")
+	assert.False(t, entries[1].RawTextTruncated, "the second is a top SQL")
+}
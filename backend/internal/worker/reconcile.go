@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"math"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// DataQualityThresholds are the divergences, in percent of the expected
+// count, from which a job's stored entries are a minor or a major
+// divergence from the JAR's counts.
+type DataQualityThresholds struct {
+	MinorPct float64
+	MajorPct float64
+}
+
+// DefaultDataQualityThresholds call a loss of one entry in a hundred minor
+// and of one in twenty major.
+var DefaultDataQualityThresholds = DataQualityThresholds{MinorPct: 1, MajorPct: 5}
+
+// SetDataQualityThresholds sets the thresholds the reconciliation of stored
+// entries classifies jobs with. Non-positive values keep the defaults.
+func (p *Pipeline) SetDataQualityThresholds(t DataQualityThresholds) {
+	p.dataQuality = t
+}
+
+func (p *Pipeline) dataQualityThresholds() DataQualityThresholds {
+	t := p.dataQuality
+	if t.MinorPct <= 0 {
+		t.MinorPct = DefaultDataQualityThresholds.MinorPct
+	}
+	if t.MajorPct <= 0 {
+		t.MajorPct = DefaultDataQualityThresholds.MajorPct
+	}
+	return t
+}
+
+// divergencePct is the distance of stored from expected in percent of
+// expected. Entries stored where none were expected diverge by 100%.
+func divergencePct(expected, stored int64) float64 {
+	if expected == stored {
+		return 0
+	}
+	if expected <= 0 {
+		return 100
+	}
+	pct := math.Abs(float64(stored-expected)) / float64(expected) * 100
+	return math.Round(pct*100) / 100
+}
+
+// classifyDivergence returns the data quality of the largest divergence of
+// a job.
+func classifyDivergence(pct float64, t DataQualityThresholds) domain.DataQuality {
+	switch {
+	case pct >= t.MajorPct:
+		return domain.DataQualityMajorDivergence
+	case pct >= t.MinorPct:
+		return domain.DataQualityMinorDivergence
+	default:
+		return domain.DataQualityOK
+	}
+}
+
+// reconcileCounts compares the JAR's General Statistics, less the entries
+// the ingestion filter rules dropped, with the entries stored per log type
+// and in total. It returns nil when the JAR reported no counts to compare
+// with.
+func reconcileCounts(stats domain.GeneralStatistics, dropped, stored map[domain.LogType]int64, t DataQualityThresholds) *domain.IngestionReconciliation {
+	reported := []struct {
+		logType domain.LogType
+		count   int64
+	}{
+		{domain.LogTypeAPI, stats.APICount},
+		{domain.LogTypeSQL, stats.SQLCount},
+		{domain.LogTypeFilter, stats.FilterCount},
+		{domain.LogTypeEscalation, stats.EscCount},
+	}
+
+	rec := &domain.IngestionReconciliation{MinorPct: t.MinorPct, MajorPct: t.MajorPct}
+	total := domain.CountReconciliation{LogType: "total"}
+	for _, r := range reported {
+		c := domain.CountReconciliation{
+			LogType:  string(r.logType),
+			JARCount: r.count,
+			Dropped:  dropped[r.logType],
+			Stored:   stored[r.logType],
+		}
+		c.Expected = max(c.JARCount-c.Dropped, 0)
+		c.DivergencePct = divergencePct(c.Expected, c.Stored)
+		rec.Counts = append(rec.Counts, c)
+
+		total.JARCount += c.JARCount
+		total.Dropped += c.Dropped
+		total.Expected += c.Expected
+		total.Stored += c.Stored
+	}
+	if total.JARCount == 0 {
+		return nil
+	}
+	total.DivergencePct = divergencePct(total.Expected, total.Stored)
+	rec.Counts = append(rec.Counts, total)
+
+	var worst float64
+	for _, c := range rec.Counts {
+		worst = max(worst, c.DivergencePct)
+	}
+	rec.Quality = classifyDivergence(worst, t)
+	return rec
+}
+
+// reconcileIngestion checks the entries stored for a job against the
+// JAR's counts once ingestion is done, records the result with the job,
+// along with the number of entries whose negative duration was clamped,
+// and raises a warning on a major divergence, the sign of entries lost on
+// the way to ClickHouse.
+func (p *Pipeline) reconcileIngestion(ctx context.Context, job *domain.AnalysisJob, stats domain.GeneralStatistics, dropped map[domain.LogType]int64, clamped int64) {
+	logger := slog.With("job_id", job.ID.String(), "tenant_id", job.TenantID.String())
+
+	stored, err := p.ch.CountJobEntriesByType(ctx, job.TenantID.String(), job.ID.String())
+	if err != nil {
+		logger.Warn("ingestion reconciliation failed (non-fatal)", "error", err)
+		p.recordEvent(*job, domain.JobEventWarning, "ingest", "ingestion reconciliation failed", map[string]any{"error": err.Error()})
+		return
+	}
+	rec := reconcileCounts(stats, dropped, stored, p.dataQualityThresholds())
+	if rec == nil {
+		return
+	}
+	rec.NegativeDurationsClamped = clamped
+	if err := p.pg.UpdateJobReconciliation(ctx, job.TenantID, job.ID, rec); err != nil {
+		logger.Warn("failed to record ingestion reconciliation", "error", err)
+	}
+	job.DataQuality = &rec.Quality
+	job.Reconciliation = rec
+
+	if rec.Quality == domain.DataQualityOK {
+		return
+	}
+	total := rec.Counts[len(rec.Counts)-1]
+	if rec.Quality == domain.DataQualityMajorDivergence {
+		divergent := make(map[string]any)
+		for _, c := range rec.Counts {
+			if c.DivergencePct >= rec.MinorPct {
+				divergent[c.LogType] = map[string]any{"expected": c.Expected, "stored": c.Stored, "divergence_pct": c.DivergencePct}
+			}
+		}
+		logger.Warn("stored entries diverge from the JAR's counts",
+			"expected", total.Expected, "stored", total.Stored, "divergence_pct", total.DivergencePct)
+		p.recordEvent(*job, domain.JobEventWarning, "ingest", "stored entries diverge from the JAR's counts",
+			map[string]any{"data_quality": rec.Quality, "counts": divergent})
+		return
+	}
+	logger.Info("stored entries differ slightly from the JAR's counts",
+		"expected", total.Expected, "stored", total.Stored, "divergence_pct", total.DivergencePct)
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jobevents"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestDivergencePct(t *testing.T) {
+	tests := []struct {
+		name             string
+		expected, stored int64
+		want             float64
+	}{
+		{"equal", 16880, 16880, 0},
+		{"nothing expected or stored", 0, 0, 0},
+		{"lost entries", 16880, 9000, 46.68},
+		{"extra entries", 1000, 1010, 1},
+		{"stored where none expected", 0, 5, 100},
+		{"all lost", 200, 0, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, divergencePct(tt.expected, tt.stored))
+		})
+	}
+}
+
+func TestClassifyDivergence(t *testing.T) {
+	th := DataQualityThresholds{MinorPct: 1, MajorPct: 5}
+	tests := []struct {
+		pct  float64
+		want domain.DataQuality
+	}{
+		{0, domain.DataQualityOK},
+		{0.99, domain.DataQualityOK},
+		{1, domain.DataQualityMinorDivergence},
+		{4.99, domain.DataQualityMinorDivergence},
+		{5, domain.DataQualityMajorDivergence},
+		{100, domain.DataQualityMajorDivergence},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, classifyDivergence(tt.pct, th), "pct %v", tt.pct)
+	}
+}
+
+func TestPipeline_DataQualityThresholdsDefaults(t *testing.T) {
+	p := NewPipeline(nil, nil, nil, nil, nil, nil, nil)
+	assert.Equal(t, DefaultDataQualityThresholds, p.dataQualityThresholds())
+
+	p.SetDataQualityThresholds(DataQualityThresholds{MajorPct: 20})
+	assert.Equal(t, DataQualityThresholds{MinorPct: DefaultDataQualityThresholds.MinorPct, MajorPct: 20}, p.dataQualityThresholds())
+}
+
+func TestReconcileCounts(t *testing.T) {
+	stats := domain.GeneralStatistics{APICount: 1000, SQLCount: 500, FilterCount: 200, EscCount: 10}
+	th := DataQualityThresholds{MinorPct: 1, MajorPct: 5}
+
+	t.Run("matching counts", func(t *testing.T) {
+		stored := map[domain.LogType]int64{
+			domain.LogTypeAPI: 1000, domain.LogTypeSQL: 500, domain.LogTypeFilter: 200, domain.LogTypeEscalation: 10,
+		}
+		rec := reconcileCounts(stats, nil, stored, th)
+		require.NotNil(t, rec)
+		assert.Equal(t, domain.DataQualityOK, rec.Quality)
+		require.Len(t, rec.Counts, 5)
+		assert.Equal(t, domain.CountReconciliation{LogType: "total", JARCount: 1710, Expected: 1710, Stored: 1710}, rec.Counts[4])
+	})
+
+	t.Run("dropped entries are expected to be missing", func(t *testing.T) {
+		dropped := map[domain.LogType]int64{domain.LogTypeAPI: 300}
+		stored := map[domain.LogType]int64{
+			domain.LogTypeAPI: 700, domain.LogTypeSQL: 500, domain.LogTypeFilter: 200, domain.LogTypeEscalation: 10,
+		}
+		rec := reconcileCounts(stats, dropped, stored, th)
+		require.NotNil(t, rec)
+		assert.Equal(t, domain.DataQualityOK, rec.Quality)
+		assert.Equal(t, domain.CountReconciliation{LogType: "API", JARCount: 1000, Dropped: 300, Expected: 700, Stored: 700}, rec.Counts[0])
+	})
+
+	t.Run("one log type slightly short", func(t *testing.T) {
+		stored := map[domain.LogType]int64{
+			domain.LogTypeAPI: 1000, domain.LogTypeSQL: 490, domain.LogTypeFilter: 200, domain.LogTypeEscalation: 10,
+		}
+		rec := reconcileCounts(stats, nil, stored, th)
+		require.NotNil(t, rec)
+		assert.Equal(t, domain.DataQualityMinorDivergence, rec.Quality)
+		assert.Equal(t, float64(2), rec.Counts[1].DivergencePct)
+		assert.Equal(t, 0.58, rec.Counts[4].DivergencePct)
+	})
+
+	t.Run("a small log type lost", func(t *testing.T) {
+		stored := map[domain.LogType]int64{
+			domain.LogTypeAPI: 1000, domain.LogTypeSQL: 500, domain.LogTypeFilter: 200,
+		}
+		rec := reconcileCounts(stats, nil, stored, th)
+		require.NotNil(t, rec)
+		assert.Equal(t, domain.DataQualityMajorDivergence, rec.Quality, "the total alone would pass")
+		assert.Equal(t, float64(100), rec.Counts[3].DivergencePct)
+	})
+
+	t.Run("no JAR counts", func(t *testing.T) {
+		assert.Nil(t, reconcileCounts(domain.GeneralStatistics{TotalLines: 40}, nil, map[domain.LogType]int64{domain.LogTypeAPI: 3}, th))
+	})
+}
+
+// TestProcessJob_ReconcilesStoredEntries runs a job whose JAR reports more
+// entries than ClickHouse holds afterwards: the job is marked a major
+// divergence and a warning is added to its event log.
+func TestProcessJob_ReconcilesStoredEntries(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+	job := newTestJob()
+	expectStructuredJob(pg, &testutil.MockNATSStreamer{}, s3, job) // publishes are set up below
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: validJAROutput}, nil).Once()
+
+	// A batch of API entries failed to land.
+	ch.On("CountJobEntriesByType", mock.Anything, job.TenantID.String(), job.ID.String()).Return(map[domain.LogType]int64{
+		domain.LogTypeAPI: 4000, domain.LogTypeSQL: 2345, domain.LogTypeFilter: 890, domain.LogTypeEscalation: 209,
+	}, nil).Once()
+	var rec *domain.IngestionReconciliation
+	pg.On("UpdateJobReconciliation", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.IngestionReconciliation")).
+		Run(func(args mock.Arguments) { rec = args.Get(3).(*domain.IngestionReconciliation) }).
+		Return(nil).Once()
+
+	var mu sync.Mutex
+	var events []domain.JobEvent
+	pg.On("AppendJobEvents", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, args.Get(1).([]domain.JobEvent)...)
+		}).
+		Return(0, nil)
+	appender := jobevents.NewAppender(pg, domain.JobEventSourceWorker, 0, 0, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go appender.Run(ctx)
+
+	var published domain.AnalysisJob
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).
+		Run(func(args mock.Arguments) { published = args.Get(3).(domain.AnalysisJob) }).
+		Return(nil)
+
+	p := NewPipeline(pg, ch, s3, nil, nats, jarRunner, nil)
+	p.SetJobEvents(appender)
+	require.NoError(t, p.ProcessJob(context.Background(), job))
+	ch.AssertExpectations(t)
+
+	require.NotNil(t, rec)
+	assert.Equal(t, domain.DataQualityMajorDivergence, rec.Quality)
+	assert.Equal(t, domain.CountReconciliation{LogType: "API", JARCount: 8901, Expected: 8901, Stored: 4000, DivergencePct: 55.06}, rec.Counts[0])
+	require.NotNil(t, published.DataQuality)
+	assert.Equal(t, domain.DataQualityMajorDivergence, *published.DataQuality)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, e := range events {
+			if e.Kind == domain.JobEventWarning && e.Message == "stored entries diverge from the JAR's counts" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,452 @@
+package worker
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// DefaultRestartWarmup is how long after a restart entries are left out
+	// of latency factors: caches are cold and first calls slow.
+	DefaultRestartWarmup = 5 * time.Minute
+
+	// restartMinGap is the shortest silence counted as logging stopping.
+	restartMinGap = 30 * time.Second
+
+	// restartWindow is how far either side of a candidate restart the
+	// threads and trace prefixes are compared.
+	restartWindow = 5 * time.Minute
+
+	// restartMergeWindow merges candidates this close into one restart.
+	restartMergeWindow = 2 * time.Minute
+
+	// restartMinConfidence is the confidence a candidate needs to be
+	// reported. A file boundary or a gap alone stays below it, so that log
+	// rotations and quiet periods are not taken for restarts.
+	restartMinConfidence = 0.5
+
+	// restartMinThreads is the number of threads needed on each side of a
+	// candidate before their turnover counts as evidence.
+	restartMinThreads = 2
+
+	// restartLowTraceCounter is the trace counter below which the counters
+	// of a new trace prefix are taken as counting from server start.
+	restartLowTraceCounter = 1000
+
+	// maxRestartTracePrefixes is the number of trace prefixes above which
+	// prefixes are not taken for server epochs, and their first appearance
+	// not for a candidate restart.
+	maxRestartTracePrefixes = 64
+
+	// maxRestartSamples bounds the entries kept while a capture is parsed.
+	maxRestartSamples = 200000
+
+	// maxBannerDetail bounds the banner text quoted as evidence.
+	maxBannerDetail = 120
+)
+
+// restartWeights are how much each signal adds to the confidence of a
+// restart. A banner alone is enough; the turnovers need a gap or each
+// other.
+var restartWeights = map[domain.RestartSignal]float64{
+	domain.RestartSignalBanner:       0.6,
+	domain.RestartSignalTraceEpoch:   0.45,
+	domain.RestartSignalThreadReset:  0.4,
+	domain.RestartSignalLoggingGap:   0.1,
+	domain.RestartSignalFileBoundary: 0.05,
+}
+
+// restartBannerRegex matches the lines the AR Server logs while it starts.
+var restartBannerRegex = regexp.MustCompile(`(?i)` +
+	`\bAR ?System server (?:is )?(?:starting|started|initiali[sz]ing|initiali[sz]ed)\b|` +
+	`\bAction Request System\b.*\bstart(?:ing|ed)\b|` +
+	`\barserverd?\b.*\bstart(?:ing|ed)\b|` +
+	`\bserver (?:startup|start-up|initiali[sz]ation) (?:complete|completed|started)\b`)
+
+// RestartSample is one log entry kept for restart detection.
+type RestartSample struct {
+	Timestamp  time.Time
+	FileNumber uint16
+	LineNumber uint32
+	ThreadID   string
+	TraceID    string
+	Text       string
+}
+
+// splitTraceID splits an AR 19+ trace ID, "<prefix>:<counter>", into the
+// prefix the server picks when it starts and the counter it increments per
+// trace. ok is false for IDs of another form.
+func splitTraceID(id string) (prefix string, counter int64, ok bool) {
+	i := strings.LastIndexByte(id, ':')
+	if i <= 0 || i == len(id)-1 {
+		return "", 0, false
+	}
+	n, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:i], n, true
+}
+
+// restartCandidate is a point of a capture a restart may have happened at.
+type restartCandidate struct {
+	at        int // Index of the first sample after the point
+	signals   map[domain.RestartSignal]string
+	downSince *time.Time
+}
+
+// DetectRestarts finds the AR Server restarts within a capture from samples
+// of its entries and the boundaries of its files. Startup banners, logging
+// gaps, file boundaries and the first appearance of a trace prefix are
+// candidates; each is then scored by the signals around it: a banner, the
+// threads logging on either side having nothing in common, the trace
+// prefixes changing, a gap before it and a file starting there. Candidates
+// reaching restartMinConfidence are returned in time order.
+//
+// Files that overlap in time were logged by different servers and are
+// searched separately; files that follow each other are one server's
+// rotated logs and are searched as one.
+func DetectRestarts(samples []RestartSample, files []domain.FileMetadata) []domain.RestartEvent {
+	streamOf, streamFiles := restartStreams(files)
+	byStream := make(map[int][]RestartSample)
+	for _, s := range samples {
+		stream := streamOf[int(s.FileNumber)]
+		byStream[stream] = append(byStream[stream], s)
+	}
+
+	var events []domain.RestartEvent
+	for stream, ss := range byStream {
+		events = append(events, detectStreamRestarts(ss, streamFiles[stream])...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp.Time) })
+	return events
+}
+
+// restartStreams assigns the files of a capture to streams: a file follows
+// on from the first stream that ended before it started, or starts a new
+// one. Files are numbered from 1; the stream of an unknown file is 0.
+func restartStreams(files []domain.FileMetadata) (map[int]int, map[int][]domain.FileMetadata) {
+	sorted := append([]domain.FileMetadata(nil), files...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime.Time) })
+
+	streamOf := make(map[int]int, len(sorted))
+	streamFiles := make(map[int][]domain.FileMetadata)
+	var ends []time.Time
+	for _, f := range sorted {
+		stream := -1
+		for i, end := range ends {
+			if !f.StartTime.Before(end) {
+				stream = i
+				break
+			}
+		}
+		if stream < 0 {
+			stream = len(ends)
+			ends = append(ends, time.Time{})
+		}
+		ends[stream] = f.EndTime.Time
+		streamOf[f.FileNumber] = stream
+		streamFiles[stream] = append(streamFiles[stream], f)
+	}
+	return streamOf, streamFiles
+}
+
+// detectStreamRestarts finds the restarts within the samples of one server.
+func detectStreamRestarts(samples []RestartSample, files []domain.FileMetadata) []domain.RestartEvent {
+	if len(samples) == 0 {
+		return nil
+	}
+	samples = append([]RestartSample(nil), samples...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+	start := samples[0].Timestamp
+
+	candidates := make(map[int]*restartCandidate)
+	candidate := func(at int) *restartCandidate {
+		c, ok := candidates[at]
+		if !ok {
+			c = &restartCandidate{at: at, signals: make(map[domain.RestartSignal]string)}
+			candidates[at] = c
+		}
+		return c
+	}
+
+	prefixes := make(map[string]bool)
+	for _, s := range samples {
+		if prefix, _, ok := splitTraceID(s.TraceID); ok {
+			prefixes[prefix] = true
+		}
+	}
+	epochs := len(prefixes) <= maxRestartTracePrefixes
+
+	seenPrefixes := make(map[string]bool)
+	for i, s := range samples {
+		if restartBannerRegex.MatchString(s.Text) {
+			text := s.Text
+			if len(text) > maxBannerDetail {
+				text = text[:maxBannerDetail] + "..."
+			}
+			candidate(i).signals[domain.RestartSignalBanner] = fmt.Sprintf("line %d: %s", s.LineNumber, text)
+		}
+		if i > 0 {
+			if gap := s.Timestamp.Sub(samples[i-1].Timestamp); gap >= restartMinGap {
+				c := candidate(i)
+				since := samples[i-1].Timestamp
+				c.downSince = &since
+				c.signals[domain.RestartSignalLoggingGap] = fmt.Sprintf("no entries for %s", gap.Round(time.Second))
+			}
+		}
+		if prefix, _, ok := splitTraceID(s.TraceID); ok && epochs && !seenPrefixes[prefix] {
+			seenPrefixes[prefix] = true
+			if s.Timestamp.Sub(start) > restartWindow {
+				candidate(i)
+			}
+		}
+	}
+	for i, f := range files {
+		if i == 0 {
+			continue
+		}
+		at := sort.Search(len(samples), func(i int) bool { return !samples[i].Timestamp.Before(f.StartTime.Time) })
+		if at == 0 || at == len(samples) {
+			continue
+		}
+		candidate(at).signals[domain.RestartSignalFileBoundary] = fmt.Sprintf("file %d (%s) starts", f.FileNumber, f.FileName)
+	}
+
+	ordered := make([]*restartCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.at > 0 {
+			ordered = append(ordered, c)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].at < ordered[j].at })
+
+	var events []domain.RestartEvent
+	for _, c := range mergeRestartCandidates(samples, ordered) {
+		scoreTurnover(samples, c)
+
+		var confidence float64
+		evidence := make([]domain.RestartEvidence, 0, len(c.signals))
+		for _, signal := range []domain.RestartSignal{
+			domain.RestartSignalBanner,
+			domain.RestartSignalTraceEpoch,
+			domain.RestartSignalThreadReset,
+			domain.RestartSignalLoggingGap,
+			domain.RestartSignalFileBoundary,
+		} {
+			if detail, ok := c.signals[signal]; ok {
+				confidence += restartWeights[signal]
+				evidence = append(evidence, domain.RestartEvidence{Signal: signal, Detail: detail})
+			}
+		}
+		confidence = math.Round(math.Min(confidence, 1)*100) / 100
+		if confidence < restartMinConfidence {
+			continue
+		}
+
+		s := samples[c.at]
+		events = append(events, domain.RestartEvent{
+			Timestamp:  domain.NewTimestamp(s.Timestamp),
+			DownSince:  domain.TimestampPtr(c.downSince),
+			FileNumber: int(s.FileNumber),
+			LineNumber: int(s.LineNumber),
+			Confidence: confidence,
+			Evidence:   evidence,
+		})
+	}
+	return events
+}
+
+// mergeRestartCandidates merges candidates within restartMergeWindow of the
+// first of a run into it, keeping a banner's position when there is one.
+func mergeRestartCandidates(samples []RestartSample, ordered []*restartCandidate) []*restartCandidate {
+	var merged []*restartCandidate
+	for _, c := range ordered {
+		if n := len(merged); n > 0 {
+			last := merged[n-1]
+			if samples[c.at].Timestamp.Sub(samples[last.at].Timestamp) <= restartMergeWindow {
+				if _, banner := c.signals[domain.RestartSignalBanner]; banner {
+					if _, had := last.signals[domain.RestartSignalBanner]; !had {
+						last.at = c.at
+					}
+				}
+				for signal, detail := range c.signals {
+					if _, ok := last.signals[signal]; !ok {
+						last.signals[signal] = detail
+					}
+				}
+				if last.downSince == nil {
+					last.downSince = c.downSince
+				}
+				continue
+			}
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// scoreTurnover adds the thread and trace prefix turnovers around a
+// candidate to its signals. The window before the candidate ends at the
+// last entry before it, so that a gap does not leave it empty.
+func scoreTurnover(samples []RestartSample, c *restartCandidate) {
+	beforeEnd := samples[c.at-1].Timestamp
+	afterStart := samples[c.at].Timestamp
+
+	beforeThreads, afterThreads := make(map[string]bool), make(map[string]bool)
+	beforePrefixes, afterPrefixes := make(map[string]bool), make(map[string]bool)
+	everBefore := make(map[string]bool)
+	lowest := int64(-1)
+
+	for i := c.at - 1; i >= 0; i-- {
+		s := samples[i]
+		inWindow := beforeEnd.Sub(s.Timestamp) <= restartWindow
+		if prefix, _, ok := splitTraceID(s.TraceID); ok {
+			everBefore[prefix] = true
+			if inWindow {
+				beforePrefixes[prefix] = true
+			}
+		}
+		if inWindow && s.ThreadID != "" {
+			beforeThreads[s.ThreadID] = true
+		}
+	}
+	for i := c.at; i < len(samples) && samples[i].Timestamp.Sub(afterStart) <= restartWindow; i++ {
+		s := samples[i]
+		if s.ThreadID != "" {
+			afterThreads[s.ThreadID] = true
+		}
+		if prefix, counter, ok := splitTraceID(s.TraceID); ok {
+			afterPrefixes[prefix] = true
+			if lowest < 0 || counter < lowest {
+				lowest = counter
+			}
+		}
+	}
+
+	if len(beforeThreads) >= restartMinThreads && len(afterThreads) >= restartMinThreads && disjoint(beforeThreads, afterThreads) {
+		c.signals[domain.RestartSignalThreadReset] = fmt.Sprintf("%d threads logged before and %d after, none on both sides",
+			len(beforeThreads), len(afterThreads))
+	}
+
+	if len(beforePrefixes) > 0 && len(afterPrefixes) > 0 && disjoint(beforePrefixes, afterPrefixes) && disjoint(everBefore, afterPrefixes) {
+		detail := fmt.Sprintf("trace IDs moved from %s to %s", joinKeys(beforePrefixes), joinKeys(afterPrefixes))
+		if lowest >= 0 && lowest < restartLowTraceCounter {
+			detail += fmt.Sprintf(", counting from %d", lowest)
+		}
+		c.signals[domain.RestartSignalTraceEpoch] = detail
+	}
+}
+
+func disjoint(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func joinKeys(m map[string]bool) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// restartCollector samples the entries of a capture for restart detection
+// while it is parsed: the first entry of each thread and of each trace
+// prefix per minute, the entries on both sides of a gap, and every entry
+// that looks like a startup banner.
+type restartCollector struct {
+	samples []RestartSample
+	seen    map[string]struct{}
+	last    map[uint16]RestartSample
+	kept    map[uint16]bool
+}
+
+func newRestartCollector() *restartCollector {
+	return &restartCollector{
+		seen: make(map[string]struct{}),
+		last: make(map[uint16]RestartSample),
+		kept: make(map[uint16]bool),
+	}
+}
+
+func (c *restartCollector) add(batch []domain.LogEntry) {
+	for i := range batch {
+		e := &batch[i]
+		s := RestartSample{
+			Timestamp:  e.Timestamp.Time,
+			FileNumber: e.FileNumber,
+			LineNumber: e.LineNumber,
+			ThreadID:   e.ThreadID,
+			TraceID:    e.TraceID,
+		}
+
+		keep := restartBannerRegex.MatchString(e.RawText)
+		if keep {
+			s.Text = e.RawText
+		}
+		minute := e.Timestamp.Unix() / 60
+		if e.ThreadID != "" && c.firstIn("t", e.ThreadID, minute) {
+			keep = true
+		}
+		if prefix, _, ok := splitTraceID(e.TraceID); ok && c.firstIn("p", prefix, minute) {
+			keep = true
+		}
+		if prev, ok := c.last[e.FileNumber]; !ok {
+			keep = true
+		} else if s.Timestamp.Sub(prev.Timestamp) >= restartMinGap {
+			if !c.kept[e.FileNumber] {
+				c.keep(prev)
+			}
+			keep = true
+		}
+
+		c.last[e.FileNumber] = s
+		c.kept[e.FileNumber] = keep
+		if keep {
+			c.keep(s)
+		}
+	}
+}
+
+// firstIn reports whether this is the first time key of a kind is seen in
+// minute, recording it.
+func (c *restartCollector) firstIn(kind, key string, minute int64) bool {
+	k := kind + "\x00" + key + "\x00" + strconv.FormatInt(minute, 10)
+	if _, ok := c.seen[k]; ok {
+		return false
+	}
+	c.seen[k] = struct{}{}
+	return true
+}
+
+func (c *restartCollector) keep(s RestartSample) {
+	if len(c.samples) < maxRestartSamples {
+		c.samples = append(c.samples, s)
+	}
+}
+
+// restartsWithWarmup sets the end of the warm-up window of each restart.
+// A zero warmup leaves them without one.
+func restartsWithWarmup(events []domain.RestartEvent, warmup time.Duration) []domain.RestartEvent {
+	if warmup <= 0 {
+		return events
+	}
+	for i := range events {
+		end := domain.NewTimestamp(events[i].Timestamp.Add(warmup))
+		events[i].WarmupEnd = &end
+	}
+	return events
+}
@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/logparser"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// collectRestartSamples parses a fixture log as ingestion does and returns
+// the samples kept for restart detection.
+func collectRestartSamples(t *testing.T, fixture string, files []domain.FileMetadata) []RestartSample {
+	t.Helper()
+	path := t.TempDir() + "/" + fixture
+	require.NoError(t, os.WriteFile(path, testutil.MustLoadFixture(t, fixture), 0o600))
+
+	c := newRestartCollector()
+	_, err := logparser.ParseCapture(context.Background(), path, "tenant", "job", files, 7, func(batch []domain.LogEntry) error {
+		c.add(batch)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, c.samples)
+	return c.samples
+}
+
+func restartTime(s string) time.Time {
+	ts, err := time.Parse("2006-01-02 15:04:05.000", s)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+func restartSignals(e domain.RestartEvent) []domain.RestartSignal {
+	var out []domain.RestartSignal
+	for _, ev := range e.Evidence {
+		out = append(out, ev.Signal)
+	}
+	return out
+}
+
+func TestDetectRestarts_GenuineRestart(t *testing.T) {
+	samples := collectRestartSamples(t, "restart_genuine.log", nil)
+
+	events := DetectRestarts(samples, nil)
+	require.Len(t, events, 1)
+	e := events[0]
+	assert.Equal(t, restartTime("2025-11-24 14:46:00.000"), e.Timestamp.Time)
+	require.NotNil(t, e.DownSince)
+	assert.Equal(t, restartTime("2025-11-24 14:41:50.040"), e.DownSince.Time)
+	assert.Equal(t, 25, e.LineNumber)
+	assert.Equal(t, []domain.RestartSignal{
+		domain.RestartSignalTraceEpoch,
+		domain.RestartSignalThreadReset,
+		domain.RestartSignalLoggingGap,
+	}, restartSignals(e))
+	assert.Contains(t, e.Evidence[0].Detail, "counting from 1")
+	assert.InDelta(t, 0.95, e.Confidence, 1e-9)
+}
+
+func TestDetectRestarts_LogRotationIsNotARestart(t *testing.T) {
+	// The capture moved to a new file after a quiet spell, but the same
+	// threads carry on with the same trace prefix.
+	files := []domain.FileMetadata{
+		{FileNumber: 1, FileName: "arapi.log.1", StartTime: domain.NewTimestamp(restartTime("2025-11-24 14:40:00.000")), EndTime: domain.NewTimestamp(restartTime("2025-11-24 14:41:50.040"))},
+		{FileNumber: 2, FileName: "arapi.log", StartTime: domain.NewTimestamp(restartTime("2025-11-24 14:42:45.000")), EndTime: domain.NewTimestamp(restartTime("2025-11-24 14:44:35.040"))},
+	}
+	samples := collectRestartSamples(t, "restart_rotation.log", files)
+
+	assert.Empty(t, DetectRestarts(samples, files))
+}
+
+func TestDetectRestarts_NoRestart(t *testing.T) {
+	for _, fixture := range []string{"ar25_sample.log", "ar9_legacy_sample.log"} {
+		t.Run(fixture, func(t *testing.T) {
+			samples := collectRestartSamples(t, fixture, nil)
+			assert.Empty(t, DetectRestarts(samples, nil))
+		})
+	}
+}
+
+func TestDetectRestarts_Banner(t *testing.T) {
+	base := restartTime("2025-11-24 09:00:00.000")
+	var samples []RestartSample
+	for i := 0; i < 6; i++ {
+		samples = append(samples, RestartSample{Timestamp: base.Add(time.Duration(i) * time.Second), ThreadID: "0000000100", LineNumber: uint32(i + 1)})
+	}
+	// The same thread logs on, without a gap or a trace ID: only the
+	// banner says the server restarted.
+	samples = append(samples, RestartSample{
+		Timestamp: base.Add(10 * time.Second), ThreadID: "0000000100", LineNumber: 7,
+		Text: "AR System server is starting (version 25.1.00)",
+	})
+
+	events := DetectRestarts(samples, nil)
+	require.Len(t, events, 1)
+	assert.Equal(t, 7, events[0].LineNumber)
+	assert.Equal(t, []domain.RestartSignal{domain.RestartSignalBanner}, restartSignals(events[0]))
+	assert.InDelta(t, 0.6, events[0].Confidence, 1e-9)
+}
+
+func TestDetectRestarts_ThreadResetAfterGapWithoutTraceIDs(t *testing.T) {
+	// AR 9.x logs have no trace IDs; new threads after a gap still count.
+	base := restartTime("2025-11-24 09:00:00.000")
+	var samples []RestartSample
+	for i, tid := range []string{"1", "2", "3", "1"} {
+		samples = append(samples, RestartSample{Timestamp: base.Add(time.Duration(i) * time.Second), ThreadID: tid})
+	}
+	for i, tid := range []string{"7", "8", "9"} {
+		samples = append(samples, RestartSample{Timestamp: base.Add(2*time.Minute + time.Duration(i)*time.Second), ThreadID: tid})
+	}
+
+	events := DetectRestarts(samples, nil)
+	require.Len(t, events, 1)
+	assert.Equal(t, []domain.RestartSignal{domain.RestartSignalThreadReset, domain.RestartSignalLoggingGap}, restartSignals(events[0]))
+
+	// The same gap with the threads carrying on is a quiet period.
+	samples[len(samples)-1].ThreadID = "1"
+	assert.Empty(t, DetectRestarts(samples, nil))
+}
+
+func TestDetectRestarts_OverlappingFilesAreSeparateServers(t *testing.T) {
+	// Two servers logged at the same time; the second restarted. The
+	// threads of the first carry on and must not hide the restart.
+	base := restartTime("2025-11-24 09:00:00.000")
+	files := []domain.FileMetadata{
+		{FileNumber: 1, StartTime: domain.NewTimestamp(base), EndTime: domain.NewTimestamp(base.Add(10 * time.Minute))},
+		{FileNumber: 2, StartTime: domain.NewTimestamp(base), EndTime: domain.NewTimestamp(base.Add(10 * time.Minute))},
+	}
+	var samples []RestartSample
+	for i := 0; i < 10; i++ {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		samples = append(samples, RestartSample{Timestamp: ts, FileNumber: 1, ThreadID: "a1", TraceID: "serverA:00001"})
+		samples = append(samples, RestartSample{Timestamp: ts.Add(time.Second), FileNumber: 1, ThreadID: "a2", TraceID: "serverA:00002"})
+	}
+	samples = append(samples,
+		RestartSample{Timestamp: base, FileNumber: 2, ThreadID: "b1", TraceID: "serverB:00500"},
+		RestartSample{Timestamp: base.Add(time.Second), FileNumber: 2, ThreadID: "b2", TraceID: "serverB:00501"},
+		RestartSample{Timestamp: base.Add(5 * time.Minute), FileNumber: 2, ThreadID: "b7", TraceID: "serverC:00001"},
+		RestartSample{Timestamp: base.Add(5*time.Minute + time.Second), FileNumber: 2, ThreadID: "b8", TraceID: "serverC:00002"},
+	)
+
+	events := DetectRestarts(samples, files)
+	require.Len(t, events, 1)
+	assert.Equal(t, 2, events[0].FileNumber)
+	assert.Equal(t, base.Add(5*time.Minute), events[0].Timestamp.Time)
+}
+
+func TestRestartsWithWarmup(t *testing.T) {
+	ts := restartTime("2025-11-24 09:00:00.000")
+	events := restartsWithWarmup([]domain.RestartEvent{{Timestamp: domain.NewTimestamp(ts)}}, 5*time.Minute)
+	require.NotNil(t, events[0].WarmupEnd)
+	assert.Equal(t, ts.Add(5*time.Minute), events[0].WarmupEnd.Time)
+
+	events = restartsWithWarmup([]domain.RestartEvent{{Timestamp: domain.NewTimestamp(ts)}}, -1)
+	assert.Nil(t, events[0].WarmupEnd)
+}
+
+func TestSplitTraceID(t *testing.T) {
+	prefix, counter, ok := splitTraceID("oKNmA5MvSwOxCzBulz9-zQ:0002868")
+	assert.True(t, ok)
+	assert.Equal(t, "oKNmA5MvSwOxCzBulz9-zQ", prefix)
+	assert.Equal(t, int64(2868), counter)
+
+	for _, id := range []string{"", "plain", ":123", "abc:", "abc:x1"} {
+		_, _, ok := splitTraceID(id)
+		assert.False(t, ok, id)
+	}
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// RetentionReconciler keeps ClickHouse retention in line with tenant plans:
+// it applies the log_entries TTL for the configured tiering and restamps the
+// stored entries of tenants whose plan changed class, or that were
+// converted from a sandbox.
+type RetentionReconciler struct {
+	pg      storage.PostgresStore
+	ch      storage.ClickHouseStore
+	tiering storage.TieringConfig
+}
+
+// NewRetentionReconciler creates a RetentionReconciler.
+func NewRetentionReconciler(pg storage.PostgresStore, ch storage.ClickHouseStore, tiering storage.TieringConfig) *RetentionReconciler {
+	return &RetentionReconciler{pg: pg, ch: ch, tiering: tiering}
+}
+
+// Run reconciles retention once and returns the number of tenants whose
+// entries were restamped. A tenant that fails to restamp keeps its old
+// class in Postgres and is retried on the next run.
+func (r *RetentionReconciler) Run(ctx context.Context) (int, error) {
+	applied, err := r.ch.EnsureRetentionTTL(ctx, r.tiering)
+	if err != nil {
+		return 0, fmt.Errorf("ensure retention ttl: %w", err)
+	}
+	if applied {
+		slog.Info("log_entries retention TTL updated",
+			"storage_policy", r.tiering.StoragePolicy,
+			"cold_volume", r.tiering.ColdVolume,
+		)
+	}
+
+	tenants, err := r.pg.ListTenants(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list tenants: %w", err)
+	}
+
+	restamped := 0
+	for _, t := range tenants {
+		want := storage.RetentionClassForTenant(&t)
+		if want == t.RetentionClass {
+			continue
+		}
+		logger := slog.With("tenant_id", t.ID.String(), "from", t.RetentionClass, "to", want)
+		if err := r.ch.UpdateTenantRetentionClass(storage.WithTenant(ctx, t.ID.String()), t.ID.String(), want); err != nil {
+			logger.Warn("retention restamp failed", "error", err)
+			continue
+		}
+		if err := r.pg.UpdateTenantRetentionClass(ctx, t.ID, want); err != nil {
+			logger.Warn("failed to record retention class", "error", err)
+			continue
+		}
+		logger.Info("tenant retention class changed")
+		restamped++
+	}
+	return restamped, nil
+}
+
+// tenantRetentionClass returns the retention class new entries of a tenant
+// are stamped with. It follows the plan, or the tenant being a sandbox,
+// rather than the reconciled class, so entries ingested after a change need
+// no restamping. Lookup failures fall back to the standard class.
+func (p *Pipeline) tenantRetentionClass(ctx context.Context, tenantID uuid.UUID) domain.RetentionClass {
+	tenant, err := p.pg.GetTenant(ctx, tenantID)
+	if err != nil {
+		slog.Warn("tenant lookup failed, using standard retention", "tenant_id", tenantID.String(), "error", err)
+		return domain.RetentionStandard
+	}
+	return storage.RetentionClassForTenant(tenant)
+}
+
+// stampRetentionClass sets the retention class on every entry of a batch.
+func stampRetentionClass(batch []domain.LogEntry, class domain.RetentionClass) {
+	for i := range batch {
+		batch[i].RetentionClass = class
+	}
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestRetentionReconciler_Run(t *testing.T) {
+	tiering := storage.TieringConfig{StoragePolicy: "hot_cold", ColdVolume: "cold"}
+	current := domain.Tenant{ID: uuid.New(), Plan: "enterprise", RetentionClass: domain.RetentionEnterprise}
+	upgraded := domain.Tenant{ID: uuid.New(), Plan: "enterprise", RetentionClass: domain.RetentionStandard}
+	failing := domain.Tenant{ID: uuid.New(), Plan: "trial", RetentionClass: domain.RetentionStandard}
+
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	ch.On("EnsureRetentionTTL", mock.Anything, tiering).Return(true, nil)
+	pg.On("ListTenants", mock.Anything).Return([]domain.Tenant{current, upgraded, failing}, nil)
+	ch.On("UpdateTenantRetentionClass", mock.Anything, upgraded.ID.String(), domain.RetentionEnterprise).Return(nil)
+	pg.On("UpdateTenantRetentionClass", mock.Anything, upgraded.ID, domain.RetentionEnterprise).Return(nil)
+	ch.On("UpdateTenantRetentionClass", mock.Anything, failing.ID.String(), domain.RetentionTrial).Return(errors.New("mutation rejected"))
+
+	n, err := NewRetentionReconciler(pg, ch, tiering).Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	pg.AssertNotCalled(t, "UpdateTenantRetentionClass", mock.Anything, failing.ID, mock.Anything)
+}
+
+func TestRetentionReconciler_TTLFails(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	ch.On("EnsureRetentionTTL", mock.Anything, storage.TieringConfig{}).Return(false, errors.New("readonly"))
+
+	_, err := NewRetentionReconciler(pg, ch, storage.TieringConfig{}).Run(context.Background())
+	assert.ErrorContains(t, err, "ensure retention ttl")
+	pg.AssertNotCalled(t, "ListTenants", mock.Anything)
+}
+
+func TestPipeline_TenantRetentionClass(t *testing.T) {
+	trial := uuid.New()
+	missing := uuid.New()
+	pg := new(testutil.MockPostgresStore)
+	pg.On("GetTenant", mock.Anything, trial).Return(&domain.Tenant{ID: trial, Plan: "trial"}, nil)
+	pg.On("GetTenant", mock.Anything, missing).Return(nil, errors.New("postgres: tenant not found"))
+
+	p := &Pipeline{pg: pg}
+	assert.Equal(t, domain.RetentionTrial, p.tenantRetentionClass(context.Background(), trial))
+	assert.Equal(t, domain.RetentionStandard, p.tenantRetentionClass(context.Background(), missing))
+}
+
+func TestStampRetentionClass(t *testing.T) {
+	batch := []domain.LogEntry{{EntryID: "a"}, {EntryID: "b", RetentionClass: domain.RetentionTrial}}
+	stampRetentionClass(batch, domain.RetentionEnterprise)
+	for _, e := range batch {
+		assert.Equal(t, domain.RetentionEnterprise, e.RetentionClass)
+	}
+}
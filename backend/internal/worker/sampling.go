@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/logparser"
+)
+
+const (
+	// hotWindowPadding is how far a hot window reaches on either side of
+	// the anomaly or error rate crossing it was opened for.
+	hotWindowPadding = 5 * time.Minute
+
+	// maxHotWindows caps the hot windows of a capture, earliest first, so
+	// that a capture full of anomalies is not ingested in full by the back
+	// door.
+	maxHotWindows = 20
+)
+
+// Hot window reasons.
+const (
+	hotWindowAnomaly      = "anomaly"
+	hotWindowErrorOnset   = "error_rate_crossing"
+	hotWindowMergedReason = "merged"
+)
+
+// sampler decides which entries of a sampled capture are ingested and the
+// weight each carries.
+//
+// An entry is kept with weight 1 when it failed or took at least the slow
+// threshold. Otherwise the trace it belongs to is hashed and one trace in
+// rate is kept, each of its entries with weight rate, so that the entries
+// of a transaction are kept or left out together. The decision depends on
+// the entry alone, so the second pass over the capture knows which entries
+// of the hot windows the first pass left out.
+type sampler struct {
+	rate   uint32
+	slowMS uint32
+
+	sampled, full, skipped int64
+}
+
+// newSampler returns the sampler of a job's sampling, or nil when the job
+// ingests every entry.
+func newSampler(s *domain.Sampling) *sampler {
+	if s == nil || s.Rate < domain.MinSampleRate {
+		return nil
+	}
+	slow := s.SlowThresholdMS
+	if slow == 0 {
+		slow = domain.DefaultSamplingSlowThresholdMS
+	}
+	return &sampler{rate: uint32(s.Rate), slowMS: slow}
+}
+
+// weight returns the weight the first pass gives e, 0 when it leaves e out.
+func (s *sampler) weight(e *domain.LogEntry) uint32 {
+	if !e.Success || e.ErrorEncountered || e.ErrorMessage != "" || e.DurationMS >= s.slowMS {
+		return 1
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(sampleKey(e)))
+	if h.Sum64()%uint64(s.rate) == 0 {
+		return s.rate
+	}
+	return 0
+}
+
+// sampleKey is what entries are sampled by: their trace, else their RPC on
+// its thread, else the entry itself.
+func sampleKey(e *domain.LogEntry) string {
+	switch {
+	case e.TraceID != "":
+		return "t:" + e.TraceID
+	case e.RPCID != "":
+		return "r:" + e.RPCID + "/" + e.ThreadID
+	default:
+		return "l:" + strconv.Itoa(int(e.FileNumber)) + ":" + strconv.Itoa(int(e.LineNumber))
+	}
+}
+
+// apply keeps the entries of batch the first pass ingests, in place, with
+// their weights set, and counts them.
+func (s *sampler) apply(batch []domain.LogEntry) []domain.LogEntry {
+	kept := batch[:0]
+	for i := range batch {
+		w := s.weight(&batch[i])
+		switch w {
+		case 0:
+			s.skipped++
+			continue
+		case 1:
+			s.full++
+		default:
+			s.sampled++
+		}
+		batch[i].SampleWeight = w
+		kept = append(kept, batch[i])
+	}
+	return kept
+}
+
+// backfill keeps the entries of batch within windows that the first pass
+// left out, in place, with weight 1. The sampled entries of the windows
+// are counted as ingested in full: their weights are reset once the second
+// pass is done.
+func (s *sampler) backfill(batch []domain.LogEntry, windows []domain.HotWindow) []domain.LogEntry {
+	kept := batch[:0]
+	for i := range batch {
+		if !inHotWindow(windows, batch[i].Timestamp.Time) {
+			continue
+		}
+		switch s.weight(&batch[i]) {
+		case 0:
+			s.skipped--
+			s.full++
+			batch[i].SampleWeight = 1
+			kept = append(kept, batch[i])
+		case 1:
+		default:
+			s.sampled--
+			s.full++
+		}
+	}
+	return kept
+}
+
+// record copies the sampler's counts and the hot windows into sampling.
+func (s *sampler) record(sampling *domain.Sampling, windows []domain.HotWindow) {
+	sampling.SlowThresholdMS = s.slowMS
+	sampling.HotWindows = windows
+	sampling.SampledEntries = s.sampled
+	sampling.FullFidelityEntries = s.full
+	sampling.SkippedEntries = s.skipped
+}
+
+func inHotWindow(windows []domain.HotWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// hotWindows returns the periods of a sampled capture to ingest in full:
+// around the top-N entries found anomalous and the first crossing of each
+// error rate threshold of onset, which may be nil. Overlapping windows are
+// merged and at most maxHotWindows are kept, earliest first.
+func hotWindows(dashboard *domain.DashboardData, anomalies []Anomaly, onset *domain.ErrorOnset) []domain.HotWindow {
+	type mark struct {
+		at     time.Time
+		reason string
+	}
+	var marks []mark
+
+	for _, a := range anomalies {
+		var entries []domain.TopNEntry
+		switch a.Type {
+		case AnomalySlowAPI:
+			entries = dashboard.TopAPICalls
+		case AnomalySlowSQL:
+			entries = dashboard.TopSQL
+		}
+		for _, e := range entries {
+			if e.Identifier == a.Key && float64(e.DurationMS) == a.Value && !e.Timestamp.IsZero() {
+				marks = append(marks, mark{e.Timestamp.Time, hotWindowAnomaly})
+			}
+		}
+	}
+	if onset != nil {
+		for _, c := range onset.Thresholds {
+			if c.CrossedAt != nil {
+				marks = append(marks, mark{c.CrossedAt.Time, hotWindowErrorOnset})
+			}
+		}
+	}
+	if len(marks) == 0 {
+		return nil
+	}
+
+	sort.Slice(marks, func(i, j int) bool { return marks[i].at.Before(marks[j].at) })
+	var windows []domain.HotWindow
+	for _, m := range marks {
+		start, end := m.at.Add(-hotWindowPadding), m.at.Add(hotWindowPadding)
+		if n := len(windows); n > 0 && !start.After(windows[n-1].End.Time) {
+			last := &windows[n-1]
+			if end.After(last.End.Time) {
+				last.End = domain.NewTimestamp(end)
+			}
+			if last.Reason != m.reason {
+				last.Reason = hotWindowMergedReason
+			}
+			continue
+		}
+		if len(windows) == maxHotWindows {
+			break
+		}
+		windows = append(windows, domain.HotWindow{
+			Start:  domain.NewTimestamp(start),
+			End:    domain.NewTimestamp(end),
+			Reason: m.reason,
+		})
+	}
+	return windows
+}
+
+// finishSampling runs the second pass over a sampled capture whose first
+// pass succeeded: it ingests in full the hot windows around the anomalies
+// and the error rate crossings of the first pass, then records how the
+// capture was sampled. prepare is applied to each batch of the second pass
+// before the entries the first pass left out are picked; it must do what
+// the first pass did before sampling. Its batches are checkpointed by c
+// after those of the first pass. Failures are logged and otherwise
+// ignored.
+func (p *Pipeline) finishSampling(ctx context.Context, job *domain.AnalysisJob, s *sampler, c *checkpointer, dashboard *domain.DashboardData, anomalies []Anomaly,
+	path string, files []domain.FileMetadata, prepare func([]domain.LogEntry) []domain.LogEntry) {
+	logger := slog.With("job_id", job.ID, "tenant_id", job.TenantID)
+
+	onset, err := p.ch.GetErrorOnset(ctx, job.TenantID.String(), job.ID.String())
+	if err != nil {
+		logger.Warn("error onset of the sample not available, hot windows from anomalies only", "error", err)
+		onset = nil
+	}
+	windows := hotWindows(dashboard, anomalies, onset)
+	if len(windows) > 0 {
+		finish := p.startStage(*job, "hot_windows")
+		inserted, err := p.ingestHotWindows(ctx, *job, s, c, windows, path, files, prepare)
+		if err != nil {
+			logger.Warn("hot window ingestion failed (non-fatal)", "error", err, "entries_inserted", inserted)
+			p.recordEvent(*job, domain.JobEventWarning, "hot_windows", "hot window ingestion failed",
+				map[string]any{"error": err.Error(), "entries_inserted": inserted})
+		} else {
+			logger.Info("hot windows ingested in full", "windows", len(windows), "entries_inserted", inserted)
+			finish(map[string]any{"windows": len(windows), "entries_inserted": inserted})
+		}
+	}
+
+	s.record(job.Sampling, windows)
+	if err := p.pg.UpdateJobSampling(ctx, job.TenantID, job.ID, job.Sampling); err != nil {
+		logger.Warn("failed to record sampling", "error", err)
+	}
+}
+
+// ingestHotWindows reads the capture again and inserts the entries within
+// windows that the first pass left out, then resets the weights of those it
+// sampled. It returns the entries inserted.
+func (p *Pipeline) ingestHotWindows(ctx context.Context, job domain.AnalysisJob, s *sampler, c *checkpointer, windows []domain.HotWindow,
+	path string, files []domain.FileMetadata, prepare func([]domain.LogEntry) []domain.LogEntry) (int64, error) {
+	tenantID, jobID := job.TenantID.String(), job.ID.String()
+	var inserted int64
+	_, err := logparser.ParseCapture(ctx, path, tenantID, jobID, files, 5000, func(batch []domain.LogEntry) error {
+		batch = s.backfill(prepare(batch), windows)
+		if err := p.insertBatch(ctx, c, batch); err != nil {
+			return err
+		}
+		inserted += int64(len(batch))
+		return nil
+	})
+	if err != nil {
+		return inserted, fmt.Errorf("second pass: %w", err)
+	}
+	if err := p.ch.ResetSampleWeights(ctx, tenantID, jobID, windows); err != nil {
+		return inserted, err
+	}
+	return inserted, nil
+}
@@ -0,0 +1,266 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+var sampleBase = time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+
+func TestNewSampler(t *testing.T) {
+	assert.Nil(t, newSampler(nil))
+	assert.Nil(t, newSampler(&domain.Sampling{Rate: 1}))
+
+	s := newSampler(&domain.Sampling{Rate: 10})
+	require.NotNil(t, s)
+	assert.Equal(t, uint32(10), s.rate)
+	assert.Equal(t, uint32(domain.DefaultSamplingSlowThresholdMS), s.slowMS)
+}
+
+func TestSampler_AlwaysKeepsFailedAndSlowEntries(t *testing.T) {
+	s := newSampler(&domain.Sampling{Rate: 1000, SlowThresholdMS: 500})
+	for i := 0; i < 200; i++ {
+		trace := fmt.Sprintf("tr-%d", i)
+		assert.Equal(t, uint32(1), s.weight(&domain.LogEntry{TraceID: trace, Success: false}))
+		assert.Equal(t, uint32(1), s.weight(&domain.LogEntry{TraceID: trace, Success: true, ErrorEncountered: true}))
+		assert.Equal(t, uint32(1), s.weight(&domain.LogEntry{TraceID: trace, Success: true, DurationMS: 500}))
+		assert.NotEqual(t, uint32(1), s.weight(&domain.LogEntry{TraceID: trace, Success: true, DurationMS: 499}))
+	}
+}
+
+func TestSampler_KeepsTracesTogether(t *testing.T) {
+	s := newSampler(&domain.Sampling{Rate: 4})
+	var batch []domain.LogEntry
+	for tr := 0; tr < 100; tr++ {
+		for i := 0; i < 5; i++ {
+			batch = append(batch, domain.LogEntry{TraceID: fmt.Sprintf("tr-%d", tr), Success: true, LineNumber: uint32(tr*5 + i)})
+		}
+	}
+	kept := s.apply(batch)
+
+	perTrace := make(map[string]int)
+	for _, e := range kept {
+		assert.Equal(t, uint32(4), e.SampleWeight)
+		perTrace[e.TraceID]++
+	}
+	require.NotEmpty(t, perTrace)
+	for trace, n := range perTrace {
+		assert.Equal(t, 5, n, "trace %s is kept whole", trace)
+	}
+	assert.Equal(t, int64(len(kept)), s.sampled)
+	assert.Equal(t, int64(500-len(kept)), s.skipped)
+
+	// Entries without a trace are sampled by their RPC on its thread.
+	a := domain.LogEntry{RPCID: "77", ThreadID: "t1", Success: true, LineNumber: 1}
+	b := domain.LogEntry{RPCID: "77", ThreadID: "t1", Success: true, LineNumber: 2}
+	assert.Equal(t, s.weight(&a), s.weight(&b))
+}
+
+// TestSampler_EstimatesWithinErrorBounds samples synthetic captures of
+// traces of varying size at several rates and checks that the weighted
+// counts and mean duration land within four standard errors of the truth,
+// and that error counts are exact.
+func TestSampler_EstimatesWithinErrorBounds(t *testing.T) {
+	logTypes := []domain.LogType{domain.LogTypeAPI, domain.LogTypeSQL, domain.LogTypeFilter}
+
+	for _, rate := range []int{2, 10, 50} {
+		t.Run(fmt.Sprintf("1 in %d", rate), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(int64(rate)))
+			s := newSampler(&domain.Sampling{Rate: rate, SlowThresholdMS: 2000})
+
+			var (
+				batch      []domain.LogEntry
+				trueByType = make(map[domain.LogType]float64)
+				trueErrors float64
+				trueSumMS  float64
+				// Sums over traces of squared entry counts and durations
+				// of the sampled entries, for the standard errors of the
+				// cluster sample.
+				sqByType = make(map[domain.LogType]float64)
+				sqTotal  float64
+				sqMS     float64
+			)
+			for tr := 0; tr < 20000; tr++ {
+				trace := fmt.Sprintf("%08x-%d", rng.Uint32(), tr)
+				n := 1 + rng.Intn(9)
+				perType := make(map[domain.LogType]float64)
+				var sampled, ms float64
+				for i := 0; i < n; i++ {
+					e := domain.LogEntry{
+						TraceID:    trace,
+						LogType:    logTypes[rng.Intn(len(logTypes))],
+						Success:    rng.Float64() > 0.02,
+						DurationMS: uint32(rng.ExpFloat64() * 200),
+					}
+					batch = append(batch, e)
+					trueByType[e.LogType]++
+					trueSumMS += float64(e.DurationMS)
+					if !e.Success {
+						trueErrors++
+					}
+					if s.weight(&e) != 1 {
+						perType[e.LogType]++
+						sampled++
+						ms += float64(e.DurationMS)
+					}
+				}
+				for lt, c := range perType {
+					sqByType[lt] += c * c
+				}
+				sqTotal += sampled * sampled
+				sqMS += ms * ms
+			}
+			trueTotal := float64(len(batch))
+
+			kept := s.apply(batch)
+			estByType := make(map[domain.LogType]float64)
+			var estTotal, estErrors, estSumMS float64
+			for _, e := range kept {
+				w := float64(e.SampleWeight)
+				estByType[e.LogType] += w
+				estTotal += w
+				estSumMS += w * float64(e.DurationMS)
+				if !e.Success {
+					estErrors += w
+					assert.Equal(t, uint32(1), e.SampleWeight, "failed entries are never sampled")
+				}
+			}
+
+			// Each trace is kept with probability 1/rate and its sampled
+			// entries then stand for rate entries: the variance of the
+			// estimate is (rate-1) times the sum of squared trace counts.
+			bound := func(sumSq float64) float64 { return 4 * math.Sqrt(float64(rate-1)*sumSq) }
+			for _, lt := range logTypes {
+				assert.InDelta(t, trueByType[lt], estByType[lt], bound(sqByType[lt]), "%s count", lt)
+			}
+			assert.InDelta(t, trueTotal, estTotal, bound(sqTotal), "total count")
+			assert.InDelta(t, trueSumMS, estSumMS, bound(sqMS), "total duration")
+			assert.Equal(t, trueErrors, estErrors)
+			assert.InEpsilon(t, trueSumMS/trueTotal, estSumMS/estTotal, 0.05, "mean duration")
+
+			assert.Equal(t, int64(len(batch)), s.sampled+s.full+s.skipped)
+			assert.Less(t, len(kept), len(batch))
+		})
+	}
+}
+
+func TestHotWindows(t *testing.T) {
+	at := func(min int) time.Time { return sampleBase.Add(time.Duration(min) * time.Minute) }
+	dashboard := &domain.DashboardData{
+		TopAPICalls: []domain.TopNEntry{
+			{Identifier: "GE", DurationMS: 9000, Timestamp: domain.NewTimestamp(at(10))},
+			{Identifier: "GE", DurationMS: 100, Timestamp: domain.NewTimestamp(at(40))},
+			{Identifier: "SE", DurationMS: 8000, Timestamp: domain.NewTimestamp(at(60))},
+		},
+		TopSQL: []domain.TopNEntry{
+			{Identifier: "T001", DurationMS: 7000, Timestamp: domain.NewTimestamp(at(14))},
+		},
+	}
+	anomalies := []Anomaly{
+		{Type: AnomalySlowAPI, Key: "GE", Value: 9000},
+		{Type: AnomalySlowAPI, Key: "SE", Value: 8000},
+		{Type: AnomalySlowSQL, Key: "T001", Value: 7000},
+	}
+	crossed := domain.NewTimestamp(at(62))
+	onset := &domain.ErrorOnset{Thresholds: []domain.ErrorRateCrossing{{Threshold: 0.01, CrossedAt: &crossed}, {Threshold: 0.05}}}
+
+	windows := hotWindows(dashboard, anomalies, onset)
+	require.Len(t, windows, 2)
+	assert.Equal(t, at(5), windows[0].Start.Time)
+	assert.Equal(t, at(19), windows[0].End.Time, "overlapping windows are merged")
+	assert.Equal(t, hotWindowAnomaly, windows[0].Reason)
+	assert.Equal(t, at(55), windows[1].Start.Time)
+	assert.Equal(t, at(67), windows[1].End.Time)
+	assert.Equal(t, hotWindowMergedReason, windows[1].Reason)
+	assert.False(t, inHotWindow(windows, at(40)), "only anomalous entries open windows")
+
+	assert.Nil(t, hotWindows(dashboard, nil, nil))
+
+	var many []domain.TopNEntry
+	for i := 0; i < 2*maxHotWindows; i++ {
+		many = append(many, domain.TopNEntry{Identifier: "GE", DurationMS: 9000, Timestamp: domain.NewTimestamp(at(i * 30))})
+	}
+	capped := hotWindows(&domain.DashboardData{TopAPICalls: many}, anomalies[:1], nil)
+	assert.Len(t, capped, maxHotWindows)
+	assert.Equal(t, at(-5), capped[0].Start.Time, "the earliest windows are kept")
+}
+
+func TestPipeline_FinishSampling(t *testing.T) {
+	line := func(trace string, ts time.Time) string {
+		return fmt.Sprintf("<API > <TrID: %s> <TID: 0000000100> <RPC ID: 0000005000> <Queue: Fast        > <Client-RPC: 100200   > <USER: Demo> <Overlay-Group: 1         > /* %s */ +GE HPD:Help Desk",
+			trace, ts.Format("Mon Jan 02 2006 15:04:05.0000"))
+	}
+	// One call every ten seconds for an hour, each its own trace.
+	var lines []string
+	for i := 0; i < 360; i++ {
+		lines = append(lines, line(fmt.Sprintf("tr-%d", i), sampleBase.Add(time.Duration(i)*10*time.Second)))
+	}
+	path := filepath.Join(t.TempDir(), "capture.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	job := newTestJob()
+	job.Sampling = &domain.Sampling{Rate: 5}
+	s := newSampler(job.Sampling)
+	// The first pass sampled every entry of the capture.
+	var all []domain.LogEntry
+	for i := range lines {
+		all = append(all, domain.LogEntry{TraceID: fmt.Sprintf("tr-%d", i), Success: true, Timestamp: domain.NewTimestamp(sampleBase.Add(time.Duration(i) * 10 * time.Second))})
+	}
+	s.apply(all)
+	firstSampled, firstSkipped := s.sampled, s.skipped
+
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	crossed := domain.NewTimestamp(sampleBase.Add(30 * time.Minute))
+	ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).
+		Return(&domain.ErrorOnset{Thresholds: []domain.ErrorRateCrossing{{Threshold: 0.01, CrossedAt: &crossed}}}, nil)
+	var inserted []domain.LogEntry
+	ch.On("BatchInsertEntries", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { inserted = append(inserted, args.Get(1).([]domain.LogEntry)...) }).
+		Return(nil)
+	window := domain.HotWindow{
+		Start:  domain.NewTimestamp(sampleBase.Add(25 * time.Minute)),
+		End:    domain.NewTimestamp(sampleBase.Add(35 * time.Minute)),
+		Reason: hotWindowErrorOnset,
+	}
+	ch.On("ResetSampleWeights", mock.Anything, job.TenantID.String(), job.ID.String(), []domain.HotWindow{window}).Return(nil)
+	var recorded *domain.Sampling
+	pg.On("UpdateJobSampling", mock.Anything, job.TenantID, job.ID, mock.Anything).
+		Run(func(args mock.Arguments) { recorded = args.Get(3).(*domain.Sampling) }).
+		Return(nil)
+
+	p := NewPipeline(pg, ch, nil, nil, nil, nil, nil)
+	p.finishSampling(context.Background(), &job, s, nil, &domain.DashboardData{}, nil, path, nil,
+		func(batch []domain.LogEntry) []domain.LogEntry { return batch })
+
+	// The window holds 61 calls: those the first pass left out are
+	// inserted with weight 1, those it sampled have their weights reset.
+	require.NotEmpty(t, inserted)
+	for _, e := range inserted {
+		assert.True(t, window.Contains(e.Timestamp.Time), "entry at %s", e.Timestamp)
+		assert.Equal(t, uint32(1), e.SampleWeight)
+		assert.Equal(t, uint32(0), s.weight(&e), "only entries the first pass left out are inserted")
+	}
+	require.NotNil(t, recorded)
+	assert.Equal(t, []domain.HotWindow{window}, recorded.HotWindows)
+	assert.Equal(t, int64(61), recorded.FullFidelityEntries)
+	assert.Equal(t, firstSkipped-int64(len(inserted)), recorded.SkippedEntries)
+	assert.Equal(t, firstSampled-(61-int64(len(inserted))), recorded.SampledEntries)
+	assert.Equal(t, int64(360), recorded.SampledEntries+recorded.FullFidelityEntries+recorded.SkippedEntries)
+	ch.AssertExpectations(t)
+	pg.AssertExpectations(t)
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// SandboxCleaner retires sandbox tenants once they expire: every analysis
+// they hold is purged, with its log entries, cached sections and objects,
+// and the tenant is archived.
+type SandboxCleaner struct {
+	pg     storage.PostgresStore
+	purger *JobPurger
+	s3     storage.ObjectStorage
+}
+
+// NewSandboxCleaner creates a SandboxCleaner purging analyses with purger.
+func NewSandboxCleaner(pg storage.PostgresStore, purger *JobPurger, s3 storage.ObjectStorage) *SandboxCleaner {
+	return &SandboxCleaner{pg: pg, purger: purger, s3: s3}
+}
+
+// Run retires the sandboxes expired at now and returns how many were
+// archived. A sandbox with an analysis that fails to purge is not archived
+// and is retried on the next run.
+func (c *SandboxCleaner) Run(ctx context.Context, now time.Time) (int, error) {
+	tenants, err := c.pg.ListExpiredSandboxTenants(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("list expired sandbox tenants: %w", err)
+	}
+
+	archived := 0
+	for i := range tenants {
+		if err := c.retire(ctx, &tenants[i], now); err != nil {
+			slog.Warn("failed to retire expired sandbox tenant", "tenant_id", tenants[i].ID.String(), "error", err)
+			continue
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// retire purges the analyses of one expired sandbox and archives it.
+func (c *SandboxCleaner) retire(ctx context.Context, tenant *domain.Tenant, now time.Time) error {
+	tenantID := tenant.ID.String()
+	ctx = storage.WithTenant(ctx, tenantID)
+
+	jobs, err := c.pg.ListJobs(ctx, tenant.ID)
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	deleted, err := c.pg.ListDeletedJobs(ctx, tenant.ID)
+	if err != nil {
+		return fmt.Errorf("list deleted jobs: %w", err)
+	}
+	for _, job := range append(jobs, deleted...) {
+		if err := c.purger.Purge(ctx, &job); err != nil {
+			return fmt.Errorf("purge job %s: %w", job.ID, err)
+		}
+	}
+
+	keys, err := c.pg.ArchiveTenant(ctx, tenant.ID, now)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	for _, key := range keys {
+		if err := c.s3.Delete(ctx, key); err != nil {
+			slog.Warn("failed to delete file of archived sandbox", "tenant_id", tenantID, "s3_key", key, "error", err)
+		}
+	}
+
+	slog.Info("expired sandbox tenant archived", "tenant_id", tenantID, "analyses", len(jobs)+len(deleted), "files", len(keys))
+	return nil
+}
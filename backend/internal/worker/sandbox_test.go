@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestSandboxCleaner_Run(t *testing.T) {
+	now := time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC)
+	retired := domain.Tenant{ID: uuid.New(), Sandbox: true}
+	stuck := domain.Tenant{ID: uuid.New(), Sandbox: true}
+	live := domain.AnalysisJob{ID: uuid.New(), TenantID: retired.ID}
+	trashed := domain.AnalysisJob{ID: uuid.New(), TenantID: retired.ID}
+	failing := domain.AnalysisJob{ID: uuid.New(), TenantID: stuck.ID}
+
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	s3 := new(testutil.MockObjectStorage)
+
+	pg.On("ListExpiredSandboxTenants", mock.Anything, now).Return([]domain.Tenant{retired, stuck}, nil)
+
+	pg.On("ListJobs", mock.Anything, retired.ID).Return([]domain.AnalysisJob{live}, nil)
+	pg.On("ListDeletedJobs", mock.Anything, retired.ID).Return([]domain.AnalysisJob{trashed}, nil)
+	for _, job := range []domain.AnalysisJob{live, trashed} {
+		ch.On("DeleteJobEntries", mock.Anything, retired.ID.String(), job.ID.String()).Return(nil)
+		pg.On("PurgeJob", mock.Anything, retired.ID, job.ID).Return(&domain.PurgedJob{}, nil)
+		s3.On("Delete", mock.Anything, JAROutputKey(retired.ID.String(), job.ID.String())).Return(nil)
+		s3.On("Delete", mock.Anything, JARCheckpointKey(retired.ID.String(), job.ID.String())).Return(nil)
+	}
+	pg.On("ArchiveTenant", mock.Anything, retired.ID, now).Return([]string{"tenants/t/files/unanalysed.log"}, nil)
+	s3.On("Delete", mock.Anything, "tenants/t/files/unanalysed.log").Return(nil)
+
+	pg.On("ListJobs", mock.Anything, stuck.ID).Return([]domain.AnalysisJob{failing}, nil)
+	pg.On("ListDeletedJobs", mock.Anything, stuck.ID).Return(nil, nil)
+	ch.On("DeleteJobEntries", mock.Anything, stuck.ID.String(), failing.ID.String()).Return(errors.New("mutation rejected"))
+
+	purger := NewJobPurger(pg, ch, nil, s3, time.Hour)
+	n, err := NewSandboxCleaner(pg, purger, s3).Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	s3.AssertExpectations(t)
+	// A sandbox with an analysis left to purge is retried on the next run.
+	pg.AssertNotCalled(t, "ArchiveTenant", mock.Anything, stuck.ID, mock.Anything)
+}
+
+func TestSandboxCleaner_ListFails(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListExpiredSandboxTenants", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	_, err := NewSandboxCleaner(pg, nil, nil).Run(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "list expired sandbox tenants")
+}
@@ -0,0 +1,170 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/config"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// ErrHeapBudgetExceeded is returned when a job requests more JVM heap than
+// the scheduler's total budget, so it could never be admitted.
+var ErrHeapBudgetExceeded = errors.New("job heap request exceeds worker heap budget")
+
+// Scheduler bounds how many jobs a worker runs in parallel and how much JVM
+// heap those jobs may request in total. A job is admitted only when a slot
+// is free AND its heap request fits alongside the jobs already running.
+type Scheduler struct {
+	maxJobs       int
+	heapBudgetMB  int
+	defaultHeapMB int
+	// settings, when set, replaces defaultHeapMB at each admission.
+	settings config.Dynamic
+
+	mu        sync.Mutex
+	running   int
+	heapInUse int
+	// changed is closed and replaced whenever capacity is released so that
+	// waiting Acquire calls re-check admission.
+	changed chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// NewScheduler creates a Scheduler. maxJobs <= 0 is treated as 1.
+// heapBudgetMB <= 0 disables the heap gate. defaultHeapMB is the heap
+// assumed for jobs that do not request one explicitly.
+func NewScheduler(maxJobs, heapBudgetMB, defaultHeapMB int) *Scheduler {
+	if maxJobs <= 0 {
+		maxJobs = 1
+	}
+	return &Scheduler{
+		maxJobs:       maxJobs,
+		heapBudgetMB:  heapBudgetMB,
+		defaultHeapMB: defaultHeapMB,
+		changed:       make(chan struct{}),
+	}
+}
+
+// SetDynamic makes the scheduler read the heap of jobs that do not request
+// one from settings, as the JAR runner does.
+func (s *Scheduler) SetDynamic(settings config.Dynamic) {
+	s.settings = settings
+}
+
+// Acquire blocks until a job requesting heapMB can be admitted, then reserves
+// a slot and the heap. The returned release func must be called exactly once
+// when the job finishes, whether it succeeded or failed.
+func (s *Scheduler) Acquire(ctx context.Context, heapMB int) (func(), error) {
+	for {
+		release, changed, err := s.tryAcquire(heapMB)
+		if err != nil || release != nil {
+			return release, err
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAcquire admits a job requesting heapMB if it fits now. Otherwise the
+// release func is nil and changed is closed the next time capacity is
+// released.
+func (s *Scheduler) tryAcquire(heapMB int) (release func(), changed <-chan struct{}, err error) {
+	if heapMB <= 0 {
+		heapMB = s.defaultHeapMB
+		if s.settings != nil {
+			heapMB = s.settings.JARHeapMB()
+		}
+	}
+	if s.heapBudgetMB > 0 && heapMB > s.heapBudgetMB {
+		return nil, nil, fmt.Errorf("%w: requested %d MB, budget %d MB", ErrHeapBudgetExceeded, heapMB, s.heapBudgetMB)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fits(heapMB) {
+		return nil, s.changed, nil
+	}
+	s.running++
+	s.heapInUse += heapMB
+	return s.releaseFunc(heapMB), nil, nil
+}
+
+// Dispatch waits for admission and then runs fn in its own goroutine with a
+// fresh per-job context bounded by defaultJobTimeout. It returns once the job
+// has been admitted (or admission failed), so callers such as the NATS
+// consumer are naturally throttled while the worker is at capacity.
+func (s *Scheduler) Dispatch(ctx context.Context, job domain.AnalysisJob, fn func(ctx context.Context, job domain.AnalysisJob)) error {
+	release, err := s.Acquire(ctx, job.JVMHeapMB)
+	if err != nil {
+		return err
+	}
+	s.start(job, release, fn)
+	return nil
+}
+
+// start runs an admitted job in its own goroutine and releases its capacity
+// when it returns.
+func (s *Scheduler) start(job domain.AnalysisJob, release func(), fn func(ctx context.Context, job domain.AnalysisJob)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer release()
+
+		// Derive from context.Background so in-progress jobs are not
+		// aborted when the shutdown context is cancelled.
+		jobCtx, cancel := context.WithTimeout(context.Background(), defaultJobTimeout)
+		defer cancel()
+		fn(jobCtx, job)
+	}()
+}
+
+// Wait blocks until every dispatched job has finished.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Stats returns the number of running jobs and the heap they have reserved.
+func (s *Scheduler) Stats() (running int, heapInUseMB int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running, s.heapInUse
+}
+
+// capacityChanged returns a channel that is closed the next time capacity
+// is released.
+func (s *Scheduler) capacityChanged() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+// fits reports whether a job requesting heapMB can start now. Callers must
+// hold s.mu.
+func (s *Scheduler) fits(heapMB int) bool {
+	if s.running >= s.maxJobs {
+		return false
+	}
+	return s.heapBudgetMB <= 0 || s.heapInUse+heapMB <= s.heapBudgetMB
+}
+
+func (s *Scheduler) releaseFunc(heapMB int) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.running--
+			s.heapInUse -= heapMB
+			close(s.changed)
+			s.changed = make(chan struct{})
+			s.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+)
+
+// concurrencyProbe records how many jobs run at once.
+type concurrencyProbe struct {
+	current atomic.Int32
+	peak    atomic.Int32
+}
+
+func (p *concurrencyProbe) enter() {
+	n := p.current.Add(1)
+	for {
+		old := p.peak.Load()
+		if n <= old || p.peak.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
+func (p *concurrencyProbe) exit() { p.current.Add(-1) }
+
+// sleepyJAR returns a MockJARRunner whose Run sleeps for d while tracking
+// concurrency in probe.
+func sleepyJAR(probe *concurrencyProbe, d time.Duration) *MockJARRunner {
+	r := &MockJARRunner{}
+	r.On("Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			probe.enter()
+			defer probe.exit()
+			time.Sleep(d)
+		}).
+		Return(&jar.Result{Duration: d}, nil)
+	return r
+}
+
+func runJAR(r *MockJARRunner) func(ctx context.Context, job domain.AnalysisJob) {
+	return func(ctx context.Context, job domain.AnalysisJob) {
+		_, _ = r.Run(ctx, "/tmp/test.log", job.JARFlags, job.JVMHeapMB, nil)
+	}
+}
+
+func newHeapJob(heapMB int) domain.AnalysisJob {
+	return domain.AnalysisJob{ID: uuid.New(), TenantID: uuid.New(), JVMHeapMB: heapMB}
+}
+
+func TestScheduler_BoundsParallelism(t *testing.T) {
+	probe := &concurrencyProbe{}
+	runner := sleepyJAR(probe, 50*time.Millisecond)
+	s := NewScheduler(2, 0, 1024)
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, s.Dispatch(context.Background(), newHeapJob(1024), runJAR(runner)))
+	}
+	s.Wait()
+
+	assert.Equal(t, int32(2), probe.peak.Load(), "at most two jobs should run at once")
+	runner.AssertNumberOfCalls(t, "Run", 6)
+
+	running, heap := s.Stats()
+	assert.Zero(t, running)
+	assert.Zero(t, heap)
+}
+
+func TestScheduler_RunsJobsInParallel(t *testing.T) {
+	probe := &concurrencyProbe{}
+	runner := sleepyJAR(probe, 100*time.Millisecond)
+	s := NewScheduler(3, 0, 1024)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Dispatch(context.Background(), newHeapJob(1024), runJAR(runner)))
+	}
+	s.Wait()
+
+	assert.Equal(t, int32(3), probe.peak.Load())
+	assert.Less(t, time.Since(start), 250*time.Millisecond, "three 100ms jobs should overlap")
+}
+
+func TestScheduler_HeapBudgetGate(t *testing.T) {
+	probe := &concurrencyProbe{}
+	runner := sleepyJAR(probe, 50*time.Millisecond)
+	// Plenty of slots, but only 6 GB of heap: two 4 GB jobs cannot overlap.
+	s := NewScheduler(4, 6144, 1024)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Dispatch(context.Background(), newHeapJob(4096), runJAR(runner)))
+	}
+	s.Wait()
+
+	assert.Equal(t, int32(1), probe.peak.Load(), "heap budget should serialize 4 GB jobs")
+}
+
+func TestScheduler_HeapBudgetAdmitsSmallJobsAlongside(t *testing.T) {
+	s := NewScheduler(4, 6144, 1024)
+
+	releaseBig, err := s.Acquire(context.Background(), 4096)
+	require.NoError(t, err)
+
+	releaseSmall, err := s.Acquire(context.Background(), 2048)
+	require.NoError(t, err, "2 GB job fits next to a 4 GB job within 6 GB")
+
+	running, heap := s.Stats()
+	assert.Equal(t, 2, running)
+	assert.Equal(t, 6144, heap)
+
+	// A third job must wait until capacity is released.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err = s.Acquire(ctx, 1024)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	releaseBig()
+	releaseSmall()
+	running, heap = s.Stats()
+	assert.Zero(t, running)
+	assert.Zero(t, heap)
+}
+
+func TestScheduler_DefaultHeapWhenUnset(t *testing.T) {
+	s := NewScheduler(2, 8192, 4096)
+
+	release, err := s.Acquire(context.Background(), 0)
+	require.NoError(t, err)
+	_, heap := s.Stats()
+	assert.Equal(t, 4096, heap)
+	release()
+}
+
+func TestScheduler_RejectsOversizedJob(t *testing.T) {
+	s := NewScheduler(2, 4096, 1024)
+
+	err := s.Dispatch(context.Background(), newHeapJob(8192), func(context.Context, domain.AnalysisJob) {
+		t.Fatal("oversized job must not run")
+	})
+	assert.ErrorIs(t, err, ErrHeapBudgetExceeded)
+}
+
+func TestScheduler_ReleaseIsIdempotent(t *testing.T) {
+	s := NewScheduler(1, 0, 1024)
+
+	release, err := s.Acquire(context.Background(), 1024)
+	require.NoError(t, err)
+	release()
+	release()
+
+	running, heap := s.Stats()
+	assert.Zero(t, running)
+	assert.Zero(t, heap)
+}
+
+func TestScheduler_ReleasesCapacityAfterFailedJob(t *testing.T) {
+	runner := &MockJARRunner{}
+	runner.On("Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("exit code 1"))
+	s := NewScheduler(1, 2048, 1024)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Dispatch(context.Background(), newHeapJob(2048), runJAR(runner)))
+	}
+	s.Wait()
+
+	runner.AssertNumberOfCalls(t, "Run", 3)
+	running, heap := s.Stats()
+	assert.Zero(t, running)
+	assert.Zero(t, heap)
+}
@@ -0,0 +1,222 @@
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+const (
+	// DefaultClockSkewThreshold is the offset between two captured files
+	// above which the skew is reported.
+	DefaultClockSkewThreshold = 5 * time.Second
+
+	// minSkewSamples is the number of shared traces needed before an offset
+	// between two files is estimated at all.
+	minSkewSamples = 5
+
+	// skewBandMinMS and skewBandMaxMS bound how far a pair may sit from the
+	// median and still count towards the estimate. The floor keeps the band
+	// from collapsing when most traces agree to the millisecond; the cap
+	// stops two clusters of pairs from being averaged into one offset.
+	skewBandMinMS = 250
+	skewBandMaxMS = 2000
+
+	// maxSkewTraces bounds the traces tracked while collecting samples.
+	maxSkewTraces = 200000
+)
+
+// SkewPair is one trace logged in two captured files: the timestamp of its
+// first entry in the reference file and in the other file.
+type SkewPair struct {
+	Reference time.Time
+	Other     time.Time
+}
+
+// SkewEstimate is the clock offset of one file relative to another.
+type SkewEstimate struct {
+	Offset   time.Duration // Positive when the other file's clock runs ahead
+	Samples  int           // Pairs that agreed with the estimate
+	Outliers int           // Pairs rejected as outliers
+}
+
+// EstimateSkew estimates the clock offset between two files from traces
+// logged in both. The offset is the median timestamp delta after discarding
+// pairs further than three scaled median absolute deviations (bounded by
+// skewBandMinMS and skewBandMaxMS) from the first median, so a minority of
+// unrelated or long-running traces cannot drag it. It reports false when
+// there are fewer than minSkewSamples pairs or when no majority of the pairs
+// agrees on an offset.
+func EstimateSkew(pairs []SkewPair) (SkewEstimate, bool) {
+	if len(pairs) < minSkewSamples {
+		return SkewEstimate{}, false
+	}
+
+	deltas := make([]int64, len(pairs))
+	for i, p := range pairs {
+		deltas[i] = p.Other.Sub(p.Reference).Milliseconds()
+	}
+	center := medianInt64(deltas)
+
+	deviations := make([]int64, len(deltas))
+	for i, d := range deltas {
+		deviations[i] = absInt64(d - center)
+	}
+	// 1.4826 scales the MAD to a standard deviation for normal data.
+	band := min(max(int64(3*1.4826*float64(medianInt64(deviations))), skewBandMinMS), skewBandMaxMS)
+
+	inliers := make([]int64, 0, len(deltas))
+	for _, d := range deltas {
+		if absInt64(d-center) <= band {
+			inliers = append(inliers, d)
+		}
+	}
+	if len(inliers) < minSkewSamples || 2*len(inliers) <= len(deltas) {
+		return SkewEstimate{}, false
+	}
+
+	return SkewEstimate{
+		Offset:   time.Duration(medianInt64(inliers)) * time.Millisecond,
+		Samples:  len(inliers),
+		Outliers: len(deltas) - len(inliers),
+	}, true
+}
+
+// medianInt64 returns the median of values, sorting them in place.
+func medianInt64(values []int64) int64 {
+	sort.Slice(values, func(a, b int) bool { return values[a] < values[b] })
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// skewCollector records the first timestamp of every trace in each captured
+// file while a capture is parsed.
+type skewCollector struct {
+	first map[string]map[uint16]time.Time
+}
+
+func newSkewCollector() *skewCollector {
+	return &skewCollector{first: make(map[string]map[uint16]time.Time)}
+}
+
+func (c *skewCollector) add(batch []domain.LogEntry) {
+	for _, e := range batch {
+		if e.TraceID == "" {
+			continue
+		}
+		files, ok := c.first[e.TraceID]
+		if !ok {
+			if len(c.first) >= maxSkewTraces {
+				continue
+			}
+			files = make(map[uint16]time.Time, 1)
+			c.first[e.TraceID] = files
+		}
+		if ts, seen := files[e.FileNumber]; !seen || e.Timestamp.Before(ts) {
+			files[e.FileNumber] = e.Timestamp.Time
+		}
+	}
+}
+
+// pairs returns the traces seen in both the reference and the other file.
+func (c *skewCollector) pairs(reference, other uint16) []SkewPair {
+	var out []SkewPair
+	for _, files := range c.first {
+		ref, ok := files[reference]
+		if !ok {
+			continue
+		}
+		if ts, ok := files[other]; ok {
+			out = append(out, SkewPair{Reference: ref, Other: ts})
+		}
+	}
+	return out
+}
+
+// clockSkewReport compares every captured file with the first one and
+// returns the files whose offset exceeds threshold, or nil when none does.
+func clockSkewReport(c *skewCollector, files []domain.FileMetadata, threshold time.Duration) *domain.ClockSkewReport {
+	if len(files) < 2 {
+		return nil
+	}
+	fileNumber := func(i int) uint16 {
+		if n := files[i].FileNumber; n > 0 {
+			return uint16(n)
+		}
+		return uint16(i + 1)
+	}
+
+	ref := fileNumber(0)
+	report := &domain.ClockSkewReport{ThresholdMS: threshold.Milliseconds()}
+	var worst time.Duration
+	for i := 1; i < len(files); i++ {
+		est, ok := EstimateSkew(c.pairs(ref, fileNumber(i)))
+		if !ok {
+			continue
+		}
+		offset := est.Offset
+		if offset < 0 {
+			offset = -offset
+		}
+		if offset <= threshold {
+			continue
+		}
+		report.Files = append(report.Files, domain.FileClockSkew{
+			FileNumber:    int(fileNumber(i)),
+			FileName:      files[i].FileName,
+			ReferenceFile: int(ref),
+			OffsetMS:      est.Offset.Milliseconds(),
+			Samples:       est.Samples,
+		})
+		worst = max(worst, offset)
+	}
+	if len(report.Files) == 0 {
+		return nil
+	}
+
+	refName := files[0].FileName
+	if refName == "" {
+		refName = fmt.Sprintf("file %d", ref)
+	}
+	report.Warning = fmt.Sprintf("clocks of %d captured file(s) differ from %s by up to %s; cross-file gaps, durations and time series may be skewed",
+		len(report.Files), refName, worst)
+	return report
+}
+
+// applySkewCorrection shifts the timestamps of entries from skewed files.
+func applySkewCorrection(batch []domain.LogEntry, offsets map[uint16]time.Duration) {
+	if len(offsets) == 0 {
+		return
+	}
+	for i := range batch {
+		if off, ok := offsets[batch[i].FileNumber]; ok {
+			batch[i].Timestamp = domain.NewTimestamp(batch[i].Timestamp.Add(off))
+		}
+	}
+}
+
+// skewOffsets returns the correction to add to each skewed file's timestamps
+// and marks those files corrected in the report.
+func skewOffsets(report *domain.ClockSkewReport) map[uint16]time.Duration {
+	offsets := make(map[uint16]time.Duration, len(report.Files))
+	for i := range report.Files {
+		f := &report.Files[i]
+		offsets[uint16(f.FileNumber)] = -time.Duration(f.OffsetMS) * time.Millisecond
+		f.Corrected = true
+	}
+	report.Warning = fmt.Sprintf("timestamps of %d captured file(s) were shifted to match file %d",
+		len(report.Files), report.Files[0].ReferenceFile)
+	return offsets
+}
@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+var skewBase = time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC)
+
+// skewPairs builds pairs whose other timestamp is offset from the reference
+// by each of the given deltas.
+func skewPairs(deltas ...time.Duration) []SkewPair {
+	pairs := make([]SkewPair, len(deltas))
+	for i, d := range deltas {
+		ref := skewBase.Add(time.Duration(i) * time.Minute)
+		pairs[i] = SkewPair{Reference: ref, Other: ref.Add(d)}
+	}
+	return pairs
+}
+
+func TestEstimateSkew(t *testing.T) {
+	const s = time.Second
+	const ms = time.Millisecond
+
+	tests := []struct {
+		name         string
+		pairs        []SkewPair
+		wantOK       bool
+		wantOffset   time.Duration
+		wantOutliers int
+	}{
+		{
+			name:   "no overlap",
+			pairs:  nil,
+			wantOK: false,
+		},
+		{
+			name:   "too few samples",
+			pairs:  skewPairs(120*s, 120*s, 121*s, 119*s),
+			wantOK: false,
+		},
+		{
+			name:       "consistent offset",
+			pairs:      skewPairs(120*s, 120*s+5*ms, 120*s-10*ms, 120*s+20*ms, 120*s),
+			wantOK:     true,
+			wantOffset: 120 * s,
+		},
+		{
+			name:       "clock running behind",
+			pairs:      skewPairs(-90*s, -90*s, -90*s-30*ms, -90*s+40*ms, -90*s+10*ms, -90*s),
+			wantOK:     true,
+			wantOffset: -90 * s,
+		},
+		{
+			name: "adversarial outliers are rejected",
+			pairs: skewPairs(
+				120*s, 120*s+10*ms, 120*s-10*ms, 120*s+20*ms, 120*s-20*ms, 120*s, 120*s+5*ms,
+				-6*time.Hour, 72*time.Hour, 45*time.Minute, 3*time.Hour,
+			),
+			wantOK:       true,
+			wantOffset:   120 * s,
+			wantOutliers: 4,
+		},
+		{
+			name:   "no consensus",
+			pairs:  skewPairs(0, 0, 0, 0, 0, 10*time.Minute, 10*time.Minute, 10*time.Minute, 10*time.Minute, 10*time.Minute),
+			wantOK: false,
+		},
+		{
+			name:   "outliers leave too few samples",
+			pairs:  skewPairs(s, s, s, s, 10*time.Hour, -10*time.Hour, 3*time.Hour),
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			est, ok := EstimateSkew(tc.pairs)
+			require.Equal(t, tc.wantOK, ok)
+			if !tc.wantOK {
+				return
+			}
+			assert.Equal(t, tc.wantOffset, est.Offset)
+			assert.Equal(t, tc.wantOutliers, est.Outliers)
+			assert.Equal(t, len(tc.pairs)-tc.wantOutliers, est.Samples)
+		})
+	}
+}
+
+func TestClockSkewReport(t *testing.T) {
+	files := []domain.FileMetadata{
+		{FileNumber: 1, FileName: "app1.log"},
+		{FileNumber: 2, FileName: "app2.log"},
+		{FileNumber: 3, FileName: "app3.log"},
+		{FileNumber: 4, FileName: "app4.log"},
+	}
+
+	c := newSkewCollector()
+	for i := 0; i < 8; i++ {
+		ts := skewBase.Add(time.Duration(i) * time.Minute)
+		trace := fmt.Sprintf("trace-%d", i)
+		c.add([]domain.LogEntry{
+			{TraceID: trace, FileNumber: 1, Timestamp: domain.NewTimestamp(ts.Add(time.Second))},
+			{TraceID: trace, FileNumber: 1, Timestamp: domain.NewTimestamp(ts)}, // earliest entry wins
+			{TraceID: trace, FileNumber: 2, Timestamp: domain.NewTimestamp(ts.Add(-3 * time.Minute))},
+			{TraceID: trace, FileNumber: 3, Timestamp: domain.NewTimestamp(ts.Add(2 * time.Second))},
+		})
+	}
+	// File 4 shares no traces with file 1.
+	c.add([]domain.LogEntry{{TraceID: "only-4", FileNumber: 4, Timestamp: domain.NewTimestamp(skewBase)}})
+
+	report := clockSkewReport(c, files, 5*time.Second)
+	require.NotNil(t, report)
+	assert.Equal(t, int64(5000), report.ThresholdMS)
+	require.Len(t, report.Files, 1, "file 3 is within the threshold, file 4 has no overlap")
+	assert.Equal(t, domain.FileClockSkew{
+		FileNumber:    2,
+		FileName:      "app2.log",
+		ReferenceFile: 1,
+		OffsetMS:      -180000,
+		Samples:       8,
+	}, report.Files[0])
+	assert.Contains(t, report.Warning, "app1.log")
+	assert.Contains(t, report.Warning, "3m0s")
+
+	assert.Nil(t, clockSkewReport(c, files, 10*time.Minute))
+	assert.Nil(t, clockSkewReport(c, files[:1], time.Second))
+}
+
+func TestSkewCorrection(t *testing.T) {
+	report := &domain.ClockSkewReport{
+		Files: []domain.FileClockSkew{{FileNumber: 2, ReferenceFile: 1, OffsetMS: -180000}},
+	}
+	offsets := skewOffsets(report)
+	assert.True(t, report.Files[0].Corrected)
+	assert.Contains(t, report.Warning, "shifted")
+
+	batch := []domain.LogEntry{
+		{FileNumber: 1, Timestamp: domain.NewTimestamp(skewBase)},
+		{FileNumber: 2, Timestamp: domain.NewTimestamp(skewBase)},
+	}
+	applySkewCorrection(batch, offsets)
+	assert.Equal(t, skewBase, batch[0].Timestamp.Time)
+	assert.Equal(t, skewBase.Add(3*time.Minute), batch[1].Timestamp.Time)
+}
+
+func TestPipeline_DetectClockSkew(t *testing.T) {
+	line := func(trace string, ts time.Time) string {
+		return fmt.Sprintf("<API > <TrID: %s> <TID: 0000000100> <RPC ID: 0000005000> <Queue: Fast        > <Client-RPC: 100200   > <USER: Demo> <Overlay-Group: 1         > /* %s */ +GE HPD:Help Desk",
+			trace, ts.Format("Mon Jan 02 2006 15:04:05.0000"))
+	}
+
+	// The second server's clock runs two minutes behind the first.
+	var lines []string
+	for i := 0; i < 6; i++ {
+		lines = append(lines, line(fmt.Sprintf("tr-%d", i), skewBase.Add(time.Duration(i)*time.Minute)))
+	}
+	for i := 0; i < 6; i++ {
+		lines = append(lines, line(fmt.Sprintf("tr-%d", i), skewBase.Add(time.Duration(i)*time.Minute-2*time.Minute+time.Second)))
+	}
+	path := filepath.Join(t.TempDir(), "capture.log")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	files := []domain.FileMetadata{
+		{FileNumber: 1, FileName: "server1.log", StartTime: domain.NewTimestamp(skewBase), EndTime: domain.NewTimestamp(skewBase.Add(5 * time.Minute))},
+		{FileNumber: 2, FileName: "server2.log", StartTime: domain.NewTimestamp(skewBase.Add(-2 * time.Minute)), EndTime: domain.NewTimestamp(skewBase.Add(3 * time.Minute))},
+	}
+
+	p := &Pipeline{}
+	report := p.detectClockSkew(context.Background(), path, "tenant", "job", files)
+	require.NotNil(t, report)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, 2, report.Files[0].FileNumber)
+	assert.Equal(t, int64(-119000), report.Files[0].OffsetMS)
+
+	p.SetClockSkewThreshold(5 * time.Minute)
+	assert.Nil(t, p.detectClockSkew(context.Background(), path, "tenant", "job", files))
+	assert.Nil(t, p.detectClockSkew(context.Background(), path, "tenant", "job", files[:1]))
+}
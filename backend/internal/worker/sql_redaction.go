@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/sqlliteral"
+)
+
+// sqlRedactor applies a tenant's SQL redaction policy to what an analysis
+// stores: the statements of the log entries and the SQL-carrying sections
+// of the JAR report. A nil redactor stores everything as logged.
+type sqlRedactor struct {
+	replace func(sqlliteral.Literal) string
+
+	// redacted counts the entries whose statement or raw text changed.
+	redacted int64
+}
+
+// newSQLRedactor returns the redactor of policy, or nil when the policy
+// is off. Hash tokens are keyed by the tenant, so that equal values match
+// within a tenant and not across tenants.
+func newSQLRedactor(policy domain.SQLRedaction, tenantID uuid.UUID) *sqlRedactor {
+	switch policy {
+	case domain.SQLRedactionHash:
+		return &sqlRedactor{replace: sqlliteral.Hasher(tenantID[:])}
+	case domain.SQLRedactionRemove:
+		return &sqlRedactor{replace: sqlliteral.Remove}
+	}
+	return nil
+}
+
+// loadSQLRedactor loads the SQL redaction policy of the tenant. Unlike the
+// other per-tenant settings of ingestion, a policy that cannot be read
+// fails the job: storing the statements as logged could break it.
+func (p *Pipeline) loadSQLRedactor(ctx context.Context, tenantID uuid.UUID) (*sqlRedactor, error) {
+	tenant, err := p.pg.GetTenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("load sql redaction policy: %w", err)
+	}
+	return newSQLRedactor(tenant.SQLRedaction, tenantID), nil
+}
+
+func (r *sqlRedactor) statement(sql string) string {
+	return sqlliteral.Replace(sql, r.replace)
+}
+
+// quotedOnly redacts the string literals of text that is not all SQL, such
+// as a raw log line whose statement could not be located: its numbers are
+// timestamps and IDs as much as values.
+func (r *sqlRedactor) quotedOnly(text string) string {
+	return sqlliteral.Replace(text, func(lit sqlliteral.Literal) string {
+		if lit.Kind == sqlliteral.Number {
+			return lit.Text
+		}
+		return r.replace(lit)
+	})
+}
+
+// apply redacts the statements of a batch. The statement is redacted
+// where it stands in the raw text too; the rest of the line, its
+// timestamp, thread and user, is left as logged.
+func (r *sqlRedactor) apply(batch []domain.LogEntry) {
+	if r == nil {
+		return
+	}
+	for i := range batch {
+		e := &batch[i]
+		if e.SQLStatement == "" && e.LogType != domain.LogTypeSQL {
+			continue
+		}
+		raw, stmt := e.RawText, e.SQLStatement
+		if stmt != "" {
+			e.SQLStatement = r.statement(stmt)
+			if at := strings.Index(e.RawText, stmt); at >= 0 {
+				e.RawText = e.RawText[:at] + e.SQLStatement + e.RawText[at+len(stmt):]
+			} else {
+				e.RawText = r.quotedOnly(e.RawText)
+			}
+		} else {
+			e.RawText = r.quotedOnly(e.RawText)
+		}
+		if e.SQLStatement != stmt || e.RawText != raw {
+			r.redacted++
+		}
+	}
+}
+
+// parseResult redacts the statements of the top SQL table and of the SQL
+// exceptions, before the sections are derived from them and cached.
+func (r *sqlRedactor) parseResult(result *domain.ParseResult) {
+	if r == nil || result == nil {
+		return
+	}
+	if d := result.Dashboard; d != nil {
+		for i := range d.TopSQL {
+			d.TopSQL[i].Identifier = r.statement(d.TopSQL[i].Identifier)
+		}
+	}
+	if exc := result.JARExceptions; exc != nil {
+		for _, entries := range [][]domain.JARExceptionEntry{exc.APIExceptions, exc.SQLExceptions} {
+			for i := range entries {
+				entries[i].SQLStatement = r.statement(entries[i].SQLStatement)
+			}
+		}
+	}
+}
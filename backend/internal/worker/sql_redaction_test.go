@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/jar"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/sqlliteral"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+const redactionSQLLine = "<SQL > <TrID: 00000042> <TID: 0000000100> <RPC ID: 0000005000> <Queue: Fast        > <Client-RPC: 390620   > <USER: Demo > <Overlay-Group: 1         > " +
+	"/* Tue Feb 03 2026 10:05:00.0000 */ SELECT T1234.C1 FROM T1234 WHERE ((T1234.C1 = N'INC000000003816') AND (T1234.C7 < 4))"
+
+func TestNewSQLRedactor(t *testing.T) {
+	tenantID := uuid.New()
+	assert.Nil(t, newSQLRedactor(domain.SQLRedactionOff, tenantID))
+	assert.Nil(t, newSQLRedactor("", tenantID), "tenants created before the policy store nothing redacted")
+	assert.NotNil(t, newSQLRedactor(domain.SQLRedactionHash, tenantID))
+	assert.NotNil(t, newSQLRedactor(domain.SQLRedactionRemove, tenantID))
+
+	// Tokens match within a tenant, not across tenants.
+	a := newSQLRedactor(domain.SQLRedactionHash, tenantID).statement("SELECT 1 FROM T1 WHERE C1 = 'x'")
+	b := newSQLRedactor(domain.SQLRedactionHash, tenantID).statement("SELECT 1 FROM T1 WHERE C1 = N'x'")
+	c := newSQLRedactor(domain.SQLRedactionHash, uuid.New()).statement("SELECT 1 FROM T1 WHERE C1 = 'x'")
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+}
+
+func TestSQLRedactor_Apply(t *testing.T) {
+	r := newSQLRedactor(domain.SQLRedactionRemove, uuid.New())
+	const stmt = "SELECT T1.C1 FROM T1 WHERE T1.C1 = N'it''s' AND T1.C7 IN (1, 2, 0x1F)"
+	const redacted = "SELECT T1.C1 FROM T1 WHERE T1.C1 = '<redacted>' AND T1.C7 IN ('<redacted>', '<redacted>', '<redacted>')"
+	prefix := "<SQL > <TrID: 00000042> /* Tue Feb 03 2026 10:05:00.0000 */ "
+
+	tests := []struct {
+		name     string
+		e        domain.LogEntry
+		wantStmt string
+		wantRaw  string
+	}{
+		{
+			name:     "statement in the raw text",
+			e:        domain.LogEntry{LogType: domain.LogTypeSQL, SQLStatement: stmt, RawText: prefix + stmt},
+			wantStmt: redacted,
+			wantRaw:  prefix + redacted,
+		},
+		{
+			name:     "statement not found in the raw text",
+			e:        domain.LogEntry{LogType: domain.LogTypeSQL, SQLStatement: stmt, RawText: prefix + "SELECT T1.C1\nFROM T1 WHERE T1.C1 = N'it''s' AND T1.C7 = 2"},
+			wantStmt: redacted,
+			wantRaw:  prefix + "SELECT T1.C1\nFROM T1 WHERE T1.C1 = '<redacted>' AND T1.C7 = 2",
+		},
+		{
+			name:    "SQL entry without a statement",
+			e:       domain.LogEntry{LogType: domain.LogTypeSQL, RawText: prefix + "INSERT INTO T1 VALUES ('secret', 42)"},
+			wantRaw: prefix + "INSERT INTO T1 VALUES ('<redacted>', 42)",
+		},
+		{
+			name:    "other entry",
+			e:       domain.LogEntry{LogType: domain.LogTypeAPI, RawText: prefix + "+GE HPD:Help Desk 'INC000000003816'"},
+			wantRaw: prefix + "+GE HPD:Help Desk 'INC000000003816'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := []domain.LogEntry{tt.e}
+			r.apply(batch)
+			assert.Equal(t, tt.wantStmt, batch[0].SQLStatement)
+			assert.Equal(t, tt.wantRaw, batch[0].RawText)
+		})
+	}
+	assert.Equal(t, int64(3), r.redacted)
+
+	var off *sqlRedactor
+	batch := []domain.LogEntry{{LogType: domain.LogTypeSQL, SQLStatement: stmt, RawText: prefix + stmt}}
+	off.apply(batch)
+	off.parseResult(&domain.ParseResult{})
+	assert.Equal(t, stmt, batch[0].SQLStatement)
+}
+
+func TestSQLRedactor_ParseResult(t *testing.T) {
+	r := newSQLRedactor(domain.SQLRedactionRemove, uuid.New())
+	result := &domain.ParseResult{
+		Dashboard: &domain.DashboardData{
+			TopSQL:      []domain.TopNEntry{{Identifier: "SELECT C1 FROM T1 WHERE C1 = 'a'", Details: "ORA-01722: invalid number 'a'"}},
+			TopAPICalls: []domain.TopNEntry{{Identifier: "GE 'a'"}},
+		},
+		JARExceptions: &domain.JARExceptionsResponse{
+			APIExceptions: []domain.JARExceptionEntry{{SQLStatement: "UPDATE T1 SET C8 = 'b' WHERE C1 = 1"}},
+			SQLExceptions: []domain.JARExceptionEntry{{SQLStatement: "DELETE FROM T1 WHERE C1 = 'c'"}},
+		},
+	}
+	r.parseResult(result)
+
+	assert.Equal(t, "SELECT C1 FROM T1 WHERE C1 = "+sqlliteral.Placeholder, result.Dashboard.TopSQL[0].Identifier)
+	assert.Equal(t, "ORA-01722: invalid number 'a'", result.Dashboard.TopSQL[0].Details, "error messages are kept")
+	assert.Equal(t, "GE 'a'", result.Dashboard.TopAPICalls[0].Identifier)
+	assert.Equal(t, "UPDATE T1 SET C8 = '<redacted>' WHERE C1 = '<redacted>'", result.JARExceptions.APIExceptions[0].SQLStatement)
+	assert.Equal(t, "DELETE FROM T1 WHERE C1 = '<redacted>'", result.JARExceptions.SQLExceptions[0].SQLStatement)
+
+	r.parseResult(&domain.ParseResult{}) // no sections
+}
+
+// redactionWorld mocks the stores of a job whose tenant has the policy
+// policy, or whose tenant cannot be read with tenantErr set.
+type redactionWorld struct {
+	pg       *testutil.MockPostgresStore
+	ch       *testutil.MockClickHouseStore
+	redis    *testutil.MockRedisCache
+	inserted []domain.LogEntry
+	cached   map[string]any
+}
+
+func newRedactionWorld(job domain.AnalysisJob, capture string, policy domain.SQLRedaction, tenantErr error) (*redactionWorld, *Pipeline) {
+	w := &redactionWorld{
+		pg:     &testutil.MockPostgresStore{},
+		ch:     &testutil.MockClickHouseStore{},
+		redis:  &testutil.MockRedisCache{},
+		cached: map[string]any{},
+	}
+	nats := &testutil.MockNATSStreamer{}
+	s3 := &testutil.MockObjectStorage{}
+	jarRunner := &MockJARRunner{}
+
+	file := &domain.LogFile{ID: job.FileID, TenantID: job.TenantID, S3Key: "logs/test.log", SizeBytes: int64(len(capture))}
+	w.pg.On("UpdateJobStatus", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobStatus"), mock.Anything).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("int"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	nats.On("PublishJobComplete", mock.Anything, job.TenantID.String(), job.ID.String(), mock.AnythingOfType("domain.AnalysisJob")).Return(nil)
+	w.pg.On("GetLogFile", mock.Anything, job.TenantID, job.FileID).Return(file, nil)
+	w.pg.On("UpdateJobLogFormat", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.LogFormat")).Return(nil)
+	w.pg.On("UpdateJobFileIntegrity", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.FileIntegrity")).Return(nil)
+	w.pg.On("UpdateJobResources", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("domain.JobResourceUsage")).Return(nil)
+	w.pg.On("UpdateJobSectionPresence", mock.Anything, job.TenantID, job.ID, mock.AnythingOfType("*domain.SectionPresence")).Return(nil).Maybe()
+	w.pg.On("UpdateJobProgress", mock.Anything, job.TenantID, job.ID, 100, mock.AnythingOfType("*int64")).Return(nil).Maybe()
+	if tenantErr != nil {
+		w.pg.On("GetTenant", mock.Anything, job.TenantID).Return(nil, tenantErr)
+	} else {
+		w.pg.On("GetTenant", mock.Anything, job.TenantID).Return(&domain.Tenant{ID: job.TenantID, Plan: "enterprise", SQLRedaction: policy}, nil)
+	}
+	w.pg.On("UpdateJobRestarts", mock.Anything, job.TenantID, job.ID, mock.Anything).Return(nil).Maybe()
+	w.pg.On("ListIngestionFilterRules", mock.Anything, job.TenantID).Return([]domain.IngestionFilterRule{}, nil).Maybe()
+	w.pg.On("GetCaptureReference", mock.Anything, job.TenantID, job.ID).Return(nil, "", nil).Maybe()
+	w.pg.On("UpdateJobCaptureDrift", mock.Anything, job.TenantID, job.ID, mock.Anything, mock.Anything).Return(nil).Maybe()
+	expectReconciliation(w.pg, w.ch, job, validJARCounts)
+	w.ch.On("BuildJobRollup", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil).Maybe()
+	// The post-processing reads are not what these tests are about.
+	w.ch.On("GetErrorOnset", mock.Anything, job.TenantID.String(), job.ID.String()).Return(nil, errors.New("not needed")).Maybe()
+	w.ch.On("GetFocusMetrics", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything, mock.Anything).Return(nil, errors.New("not needed")).Maybe()
+	w.ch.On("GetJobVocabulary", mock.Anything, job.TenantID.String(), job.ID.String(), mock.Anything).Return(nil, errors.New("not needed")).Maybe()
+	w.ch.On("BatchInsertEntries", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { w.inserted = append(w.inserted, args.Get(1).([]domain.LogEntry)...) }).
+		Return(nil).Maybe()
+
+	w.redis.On("TenantKey", job.TenantID.String(), "dashboard", job.ID.String()).Return("dashboard").Maybe()
+	w.redis.On("Set", mock.Anything, mock.AnythingOfType("string"), mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { w.cached[args.String(1)] = args.Get(2) }).
+		Return(nil).Maybe()
+
+	s3.On("Download", mock.Anything, file.S3Key).Return(io.NopCloser(strings.NewReader(capture)), nil)
+	jarOutput := strings.Replace(validJAROutput, "SELECT * FROM T1234", "SELECT * FROM T1234 WHERE C1 = 'INC000000003816'", 1)
+	jarRunner.On("Run", mock.Anything, mock.AnythingOfType("string"), job.JARFlags, job.JVMHeapMB, mock.AnythingOfType("func(string)")).
+		Return(&jar.Result{Stdout: jarOutput}, nil)
+
+	return w, NewPipeline(w.pg, w.ch, s3, w.redis, nats, jarRunner, nil)
+}
+
+func TestProcessJob_RedactsSQL(t *testing.T) {
+	job := newTestJob()
+	w, p := newRedactionWorld(job, redactionSQLLine+"\n", domain.SQLRedactionHash, nil)
+	require.NoError(t, p.ProcessJob(context.Background(), job))
+
+	require.Len(t, w.inserted, 1)
+	e := w.inserted[0]
+	require.NotEmpty(t, e.SQLStatement)
+	assert.NotContains(t, e.SQLStatement, "INC000000003816")
+	assert.NotContains(t, e.RawText, "INC000000003816")
+	assert.Contains(t, e.SQLStatement, "SELECT T1234.C1 FROM T1234 WHERE ((T1234.C1 = '#")
+	assert.Contains(t, e.RawText, e.SQLStatement)
+
+	dashboard, ok := w.cached["dashboard"].(*domain.DashboardData)
+	require.True(t, ok, "the dashboard is cached")
+	require.Len(t, dashboard.TopSQL, 1)
+	assert.NotContains(t, dashboard.TopSQL[0].Identifier, "INC000000003816")
+	assert.True(t, strings.HasPrefix(dashboard.TopSQL[0].Identifier, "SELECT * FROM T1234 WHERE C1 = '#"))
+}
+
+func TestProcessJob_SQLRedactionOff(t *testing.T) {
+	job := newTestJob()
+	w, p := newRedactionWorld(job, redactionSQLLine+"\n", domain.SQLRedactionOff, nil)
+	require.NoError(t, p.ProcessJob(context.Background(), job))
+
+	require.Len(t, w.inserted, 1)
+	assert.Contains(t, w.inserted[0].SQLStatement, "N'INC000000003816'")
+	assert.Contains(t, w.inserted[0].RawText, "N'INC000000003816'")
+}
+
+func TestProcessJob_SQLRedactionPolicyUnreadable(t *testing.T) {
+	job := newTestJob()
+	w, p := newRedactionWorld(job, redactionSQLLine+"\n", "", errors.New("db down"))
+	err := p.ProcessJob(context.Background(), job)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "load sql redaction policy")
+	assert.Empty(t, w.inserted, "nothing is stored unredacted")
+	assert.Empty(t, w.cached)
+	w.pg.AssertCalled(t, "UpdateJobStatus", mock.Anything, job.TenantID, job.ID, domain.JobStatusFailed, mock.Anything)
+}
@@ -0,0 +1,473 @@
+package worker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// bundleSummaryTopN is the number of top entries of the summary computed
+// from ClickHouse when the analysis dashboard is no longer cached.
+const bundleSummaryTopN = 10
+
+// bundleSections are the cached report sections of an analysis copied into
+// a tenant bundle, by file name and dashboard cache key suffix. Sections
+// that have expired from the cache are left out.
+var bundleSections = []struct{ name, suffix string }{
+	{"aggregates", ":agg"},
+	{"exceptions", ":exc"},
+	{"gaps", ":gaps"},
+	{"threads", ":threads"},
+	{"filters", ":filters"},
+	{"queued_calls", ":queued"},
+	{"logging_activity", ":logging-activity"},
+	{"file_metadata", ":file-metadata"},
+}
+
+// bundleIngestion is the ingestion.json of an analysis in a tenant bundle.
+type bundleIngestion struct {
+	EntriesStored  int64                   `json:"entries_stored"`
+	TotalLines     *int64                  `json:"total_lines,omitempty"`
+	ProcessedLines *int64                  `json:"processed_lines,omitempty"`
+	LogFormat      *domain.LogFormat       `json:"log_format,omitempty"`
+	Resources      bundleResources         `json:"resources"`
+	Sections       *domain.SectionPresence `json:"sections,omitempty"`
+}
+
+type bundleResources struct {
+	PeakRSSKB  *int64 `json:"peak_rss_kb,omitempty"`
+	CPUTimeMS  *int64 `json:"cpu_time_ms,omitempty"`
+	WallTimeMS *int64 `json:"wall_time_ms,omitempty"`
+}
+
+// SetCache lets tenant bundles include the report sections cached for each
+// analysis. Without a cache only the summary is bundled.
+func (e *Exporter) SetCache(redis storage.RedisCache) {
+	e.redis = redis
+}
+
+// TenantBundleKey builds the tenant-prefixed S3 key of a tenant bundle.
+// Format: tenants/{tenantID}/exports/{exportID}.tar.gz
+func TenantBundleKey(tenantID, exportID string) string {
+	return path.Join("tenants", tenantID, "exports", exportID+".tar.gz")
+}
+
+// processTenantBundle writes everything stored for the export's tenant to a
+// tar.gz bundle and uploads it to S3. Files are staged in a temporary
+// directory, log entries streamed to it page by page, so memory use does
+// not grow with the tenant. The bundle starts with manifest.json, which
+// lists every other file with its size, SHA-256 and record count.
+func (e *Exporter) processTenantBundle(ctx context.Context, export domain.SearchExport) error {
+	tenantID := export.TenantID.String()
+	exportID := export.ID.String()
+
+	var q domain.TenantExportQuery
+	if len(export.Query) > 0 {
+		if err := json.Unmarshal(export.Query, &q); err != nil {
+			return e.failExport(ctx, export, "invalid tenant export query: "+err.Error())
+		}
+	}
+
+	tenant, err := e.pg.GetTenant(ctx, export.TenantID)
+	if err != nil {
+		return e.failExport(ctx, export, "load tenant: "+err.Error())
+	}
+	jobs, err := e.pg.ListJobs(ctx, export.TenantID)
+	if err != nil {
+		return e.failExport(ctx, export, "list analyses: "+err.Error())
+	}
+
+	dir, err := os.MkdirTemp("", "remedyiq-bundle-*")
+	if err != nil {
+		return e.failExport(ctx, export, "create staging directory: "+err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	b := &bundleStage{dir: dir}
+	manifest := domain.TenantBundleManifest{
+		Format:    domain.ExportFormatTenantBundle,
+		Version:   domain.TenantBundleVersion,
+		TenantID:  export.TenantID,
+		ExportID:  export.ID,
+		CreatedAt: time.Now().UTC(),
+		Analyses:  len(jobs),
+	}
+	if err := b.writeJSON("tenant.json", tenant, 0); err != nil {
+		return e.failExport(ctx, export, "write tenant: "+err.Error())
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		entries, annotations, err := e.bundleAnalysis(ctx, b, job, q)
+		if err != nil {
+			return e.failExport(ctx, export, fmt.Sprintf("bundle analysis %s: %s", job.ID, err))
+		}
+		manifest.LogEntries += entries
+		manifest.Annotations += annotations
+
+		// Hold back the last 5% for archiving and upload.
+		pct := (i + 1) * 95 / len(jobs)
+		if err := e.pg.UpdateSearchExportProgress(ctx, export.TenantID, export.ID, pct, int64(i+1)); err != nil {
+			slog.Warn("failed to update export progress", "export_id", exportID, "error", err)
+		}
+		_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, pct, string(domain.ExportStatusRunning),
+			fmt.Sprintf("bundled %d of %d analyses", i+1, len(jobs)))
+	}
+	manifest.Files = b.files
+
+	analyses := make([]*domain.AnalysisJob, len(jobs))
+	for i := range jobs {
+		analyses[i] = &jobs[i]
+	}
+	prov, err := e.provenance(ctx, export, analyses...)
+	if err != nil {
+		return e.failExport(ctx, export, "record provenance: "+err.Error())
+	}
+
+	tmpFile, err := os.CreateTemp("", "remedyiq-bundle-*.tar.gz")
+	if err != nil {
+		return e.failExport(ctx, export, "create temp file: "+err.Error())
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	digest := sha256.New()
+	if err := writeBundleArchive(io.MultiWriter(tmpFile, digest), dir, &manifest); err != nil {
+		return e.failExport(ctx, export, "archive bundle: "+err.Error())
+	}
+	info, err := tmpFile.Stat()
+	if err != nil {
+		return e.failExport(ctx, export, "stat bundle: "+err.Error())
+	}
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		return e.failExport(ctx, export, "rewind bundle: "+err.Error())
+	}
+
+	key := TenantBundleKey(tenantID, exportID)
+	if err := e.s3.Upload(ctx, key, tmpFile, info.Size()); err != nil {
+		return e.failExport(ctx, export, "upload export: "+err.Error())
+	}
+	prov.ContentHash = hex.EncodeToString(digest.Sum(nil))
+	if err := e.pg.CompleteSearchExport(ctx, export.TenantID, export.ID, key, int64(len(jobs)), info.Size(), prov); err != nil {
+		return e.failExport(ctx, export, "record export: "+err.Error())
+	}
+	_ = e.nats.PublishJobProgress(ctx, tenantID, exportID, 100, string(domain.ExportStatusComplete),
+		fmt.Sprintf("tenant export complete: %d analyses", len(jobs)))
+
+	slog.Info("tenant export complete", "export_id", exportID, "tenant_id", tenantID,
+		"analyses", len(jobs), "log_entries", manifest.LogEntries, "size_bytes", info.Size(), "s3_key", key)
+	return nil
+}
+
+// bundleAnalysis stages the files of one analysis under analyses/{job_id}/
+// and returns the number of log entries and annotations written.
+func (e *Exporter) bundleAnalysis(ctx context.Context, b *bundleStage, job *domain.AnalysisJob, q domain.TenantExportQuery) (int64, int, error) {
+	tenantID := job.TenantID.String()
+	jobID := job.ID.String()
+	prefix := path.Join("analyses", jobID)
+
+	if err := b.writeJSON(path.Join(prefix, "analysis.json"), job, 0); err != nil {
+		return 0, 0, err
+	}
+
+	stored, err := e.ch.CountJobEntries(ctx, tenantID, jobID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count entries: %w", err)
+	}
+	ingestion := bundleIngestion{
+		EntriesStored:  stored,
+		TotalLines:     job.TotalLines,
+		ProcessedLines: job.ProcessedLines,
+		LogFormat:      job.LogFormat,
+		Resources:      bundleResources{PeakRSSKB: job.PeakRSSKB, CPUTimeMS: job.CPUTimeMS, WallTimeMS: job.WallTimeMS},
+		Sections:       job.Sections,
+	}
+	if err := b.writeJSON(path.Join(prefix, "ingestion.json"), ingestion, 0); err != nil {
+		return 0, 0, err
+	}
+
+	events, err := e.pg.ListInvestigationEvents(ctx, job.TenantID, job.ID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("list annotations: %w", err)
+	}
+	if events == nil {
+		events = []domain.InvestigationEvent{}
+	}
+	if err := b.writeJSON(path.Join(prefix, "annotations.json"), events, int64(len(events))); err != nil {
+		return 0, 0, err
+	}
+
+	timeline, err := e.pg.ListJobEvents(ctx, job.TenantID, job.ID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("list job events: %w", err)
+	}
+	if timeline == nil {
+		timeline = []domain.JobEvent{}
+	}
+	if err := b.writeJSON(path.Join(prefix, "events.json"), timeline, int64(len(timeline))); err != nil {
+		return 0, 0, err
+	}
+
+	if job.Status == domain.JobStatusComplete {
+		if err := e.bundleSections(ctx, b, job, prefix); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var entries int64
+	if q.IncludeEntries && (len(q.EntryJobIDs) == 0 || slices.Contains(q.EntryJobIDs, job.ID)) && stored > 0 {
+		limit := e.maxRows
+		if q.MaxEntriesPerAnalysis > 0 && (limit <= 0 || q.MaxEntriesPerAnalysis < limit) {
+			limit = q.MaxEntriesPerAnalysis
+		}
+		if entries, err = e.bundleEntries(ctx, b, job, path.Join(prefix, "log_entries.ndjson.gz"), limit); err != nil {
+			return 0, 0, err
+		}
+	}
+	return entries, len(events), nil
+}
+
+// bundleSections stages the summary and the cached report sections of a
+// completed analysis. The summary is recomputed from ClickHouse once the
+// cached dashboard has expired.
+func (e *Exporter) bundleSections(ctx context.Context, b *bundleStage, job *domain.AnalysisJob, prefix string) error {
+	tenantID := job.TenantID.String()
+	jobID := job.ID.String()
+
+	var cachePrefix string
+	if e.redis != nil {
+		cachePrefix = e.redis.TenantKey(tenantID, "dashboard", jobID)
+	}
+	cached := func(suffix string) string {
+		if e.redis == nil {
+			return ""
+		}
+		v, err := e.redis.Get(ctx, cachePrefix+suffix)
+		if err != nil || !json.Valid([]byte(v)) {
+			return ""
+		}
+		return v
+	}
+
+	if v := cached(""); v != "" {
+		if err := b.writeRaw(path.Join(prefix, "summary.json"), v, 0); err != nil {
+			return err
+		}
+	} else {
+		dash, err := e.ch.GetDashboardData(ctx, tenantID, jobID, bundleSummaryTopN)
+		if err != nil {
+			return fmt.Errorf("compute summary: %w", err)
+		}
+		if err := b.writeJSON(path.Join(prefix, "summary.json"), dash, 0); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range bundleSections {
+		if v := cached(s.suffix); v != "" {
+			if err := b.writeRaw(path.Join(prefix, "sections", s.name+".json"), v, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bundleEntries streams up to limit log entries of an analysis, oldest
+// first, to a gzip-compressed NDJSON file. limit <= 0 exports them all.
+func (e *Exporter) bundleEntries(ctx context.Context, b *bundleStage, job *domain.AnalysisJob, name string, limit int64) (int64, error) {
+	f, err := b.create(name)
+	if err != nil {
+		return 0, err
+	}
+	gz := gzip.NewWriter(f)
+	w := newNDJSONWriter(gz)
+
+	q := storage.SearchQuery{ExportMode: true, IncludeNoise: true, PageSize: e.pageSize, SortBy: "timestamp", SortOrder: "asc"}
+	for page := 1; limit <= 0 || w.rows < limit; page++ {
+		q.Page = page
+		result, err := e.ch.SearchEntries(ctx, job.TenantID.String(), job.ID.String(), q)
+		if err != nil {
+			f.abort()
+			return 0, fmt.Errorf("query entries: %w", err)
+		}
+		entries := result.Entries
+		if limit > 0 && int64(len(entries)) > limit-w.rows {
+			entries = entries[:limit-w.rows]
+		}
+		for i := range entries {
+			if err := w.write(&entries[i]); err != nil {
+				f.abort()
+				return 0, fmt.Errorf("write entries: %w", err)
+			}
+		}
+		if len(result.Entries) < q.PageSize {
+			break
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		f.abort()
+		return 0, fmt.Errorf("compress entries: %w", err)
+	}
+	if err := f.close(w.rows); err != nil {
+		return 0, err
+	}
+	return w.rows, nil
+}
+
+// ndjsonWriter writes one JSON document per line. The encoder writes each
+// entry through to the underlying writer, so memory use is bounded by the
+// largest entry.
+type ndjsonWriter struct {
+	enc  *json.Encoder
+	rows int64
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &ndjsonWriter{enc: enc}
+}
+
+func (n *ndjsonWriter) write(v any) error {
+	if err := n.enc.Encode(v); err != nil {
+		return err
+	}
+	n.rows++
+	return nil
+}
+
+// bundleStage is the staging directory of a tenant bundle. It records the
+// size and SHA-256 of every file as it is written.
+type bundleStage struct {
+	dir   string
+	files []domain.TenantBundleFile
+}
+
+// bundleFile is a staged file being written.
+type bundleFile struct {
+	stage *bundleStage
+	path  string
+	file  *os.File
+	w     io.Writer
+	sum   hash.Hash
+	size  int64
+}
+
+func (b *bundleStage) create(name string) (*bundleFile, error) {
+	p := filepath.Join(b.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return nil, fmt.Errorf("create %s: %w", name, err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", name, err)
+	}
+	sum := sha256.New()
+	return &bundleFile{stage: b, path: name, file: f, w: io.MultiWriter(f, sum), sum: sum}, nil
+}
+
+func (f *bundleFile) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// close closes the file and adds it to the bundle with rows records.
+func (f *bundleFile) close(rows int64) error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", f.path, err)
+	}
+	f.stage.files = append(f.stage.files, domain.TenantBundleFile{
+		Path:      f.path,
+		SizeBytes: f.size,
+		SHA256:    hex.EncodeToString(f.sum.Sum(nil)),
+		Rows:      rows,
+	})
+	return nil
+}
+
+// abort closes the file without adding it to the bundle.
+func (f *bundleFile) abort() {
+	_ = f.file.Close()
+}
+
+func (b *bundleStage) writeJSON(name string, v any, rows int64) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", name, err)
+	}
+	return b.writeRaw(name, string(data), rows)
+}
+
+func (b *bundleStage) writeRaw(name, data string, rows int64) error {
+	f, err := b.create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, data); err != nil {
+		f.abort()
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return f.close(rows)
+}
+
+// writeBundleArchive writes the manifest followed by the staged files, in
+// the order they were staged, as a tar.gz stream to w.
+func writeBundleArchive(w io.Writer, dir string, manifest *domain.TenantBundleManifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	hdr := &tar.Header{Name: "manifest.json", Mode: 0o644, Size: int64(len(data)), ModTime: manifest.CreatedAt}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	for _, file := range manifest.Files {
+		if err := addBundleFile(tw, dir, file, manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addBundleFile(tw *tar.Writer, dir string, file domain.TenantBundleFile, modTime time.Time) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hdr := &tar.Header{Name: file.Path, Mode: 0o644, Size: file.SizeBytes, ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,249 @@
+package worker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+// readBundle returns the files of a tar.gz bundle in archive order.
+func readBundle(t *testing.T, data []byte) ([]string, map[string][]byte) {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	var order []string
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		order = append(order, hdr.Name)
+		files[hdr.Name] = body
+	}
+	return order, files
+}
+
+// ndjsonRows counts the lines of a gzip-compressed NDJSON file, checking
+// that each is a log entry.
+func ndjsonRows(t *testing.T, data []byte) int64 {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	var n int64
+	sc := bufio.NewScanner(gz)
+	for sc.Scan() {
+		var e domain.LogEntry
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		n++
+	}
+	require.NoError(t, sc.Err())
+	return n
+}
+
+func TestExporter_ProcessExport_TenantBundle(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+	redis := &testutil.MockRedisCache{}
+
+	tenant := domain.Tenant{ID: uuid.New(), Name: "Acme", Plan: "pro"}
+	done := domain.AnalysisJob{ID: uuid.New(), TenantID: tenant.ID, Status: domain.JobStatusComplete}
+	failed := domain.AnalysisJob{ID: uuid.New(), TenantID: tenant.ID, Status: domain.JobStatusFailed}
+	query, err := json.Marshal(domain.TenantExportQuery{IncludeEntries: true, MaxEntriesPerAnalysis: 3})
+	require.NoError(t, err)
+	export := domain.SearchExport{
+		ID:       uuid.New(),
+		TenantID: tenant.ID,
+		Status:   domain.ExportStatusQueued,
+		Format:   domain.ExportFormatTenantBundle,
+		Query:    query,
+	}
+	tenantID, exportID := tenant.ID.String(), export.ID.String()
+	key := TenantBundleKey(tenantID, exportID)
+
+	e := NewExporter(pg, ch, s3, nats, 0)
+	e.pageSize = 2
+	e.SetCache(redis)
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, tenant.ID, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, tenantID, exportID, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	pg.On("GetTenant", mock.Anything, tenant.ID).Return(&tenant, nil)
+	pg.On("ListJobs", mock.Anything, tenant.ID).Return([]domain.AnalysisJob{done, failed}, nil)
+
+	ch.On("CountJobEntries", mock.Anything, tenantID, done.ID.String()).Return(int64(5), nil)
+	ch.On("CountJobEntries", mock.Anything, tenantID, failed.ID.String()).Return(int64(0), nil)
+	pg.On("ListInvestigationEvents", mock.Anything, tenant.ID, done.ID).
+		Return([]domain.InvestigationEvent{{ID: uuid.New(), JobID: done.ID}, {ID: uuid.New(), JobID: done.ID}}, nil)
+	pg.On("ListInvestigationEvents", mock.Anything, tenant.ID, failed.ID).Return(nil, nil)
+	pg.On("ListJobEvents", mock.Anything, tenant.ID, done.ID).
+		Return([]domain.JobEvent{{ID: 1, JobID: done.ID, Kind: domain.JobEventCreated}, {ID: 2, JobID: done.ID, Kind: domain.JobEventCompleted}}, nil)
+	pg.On("ListJobEvents", mock.Anything, tenant.ID, failed.ID).Return(nil, nil)
+
+	prefix := "t:" + tenantID + ":dashboard:" + done.ID.String()
+	redis.On("TenantKey", tenantID, "dashboard", done.ID.String()).Return(prefix)
+	redis.On("Get", mock.Anything, prefix).Return("", errors.New("redis: nil"))
+	redis.On("Get", mock.Anything, prefix+":agg").Return(`{"api_by_form":null}`, nil)
+	redis.On("Get", mock.Anything, mock.Anything).Return("", nil)
+	ch.On("GetDashboardData", mock.Anything, tenantID, done.ID.String(), bundleSummaryTopN).
+		Return(&domain.DashboardData{GeneralStats: domain.GeneralStatistics{TotalLines: 5}}, nil)
+
+	all := exportEntries(4)
+	page := func(n int) interface{} {
+		return mock.MatchedBy(func(q storage.SearchQuery) bool { return q.Page == n && q.ExportMode && q.SortBy == "timestamp" })
+	}
+	ch.On("SearchEntries", mock.Anything, tenantID, done.ID.String(), page(1)).
+		Return(&storage.SearchResult{Entries: all[:2], TotalCount: 5}, nil).Once()
+	ch.On("SearchEntries", mock.Anything, tenantID, done.ID.String(), page(2)).
+		Return(&storage.SearchResult{Entries: all[2:4], TotalCount: 5}, nil).Once()
+
+	pg.On("UpdateSearchExportProgress", mock.Anything, tenant.ID, export.ID, 47, int64(1)).Return(nil).Once()
+	pg.On("UpdateSearchExportProgress", mock.Anything, tenant.ID, export.ID, 95, int64(2)).Return(nil).Once()
+
+	var archive []byte
+	s3.On("Upload", mock.Anything, key, mock.Anything, mock.AnythingOfType("int64")).
+		Run(func(args mock.Arguments) { archive, _ = io.ReadAll(args.Get(2).(io.Reader)) }).
+		Return(nil)
+	pg.On("CompleteSearchExport", mock.Anything, tenant.ID, export.ID, key, int64(2), mock.AnythingOfType("int64"),
+		mock.AnythingOfType("*domain.ArtifactProvenance")).Return(nil)
+
+	require.NoError(t, e.ProcessExport(context.Background(), export))
+
+	order, files := readBundle(t, archive)
+	require.NotEmpty(t, order)
+	assert.Equal(t, "manifest.json", order[0], "the manifest comes first")
+
+	var manifest domain.TenantBundleManifest
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Equal(t, domain.ExportFormatTenantBundle, manifest.Format)
+	assert.Equal(t, domain.TenantBundleVersion, manifest.Version)
+	assert.Equal(t, tenant.ID, manifest.TenantID)
+	assert.Equal(t, 2, manifest.Analyses)
+	assert.Equal(t, int64(3), manifest.LogEntries, "capped per analysis")
+	assert.Equal(t, 2, manifest.Annotations)
+
+	// Every file of the bundle is listed with its size and checksum, and
+	// nothing else is.
+	listed := make(map[string]bool)
+	for _, f := range manifest.Files {
+		listed[f.Path] = true
+		body, ok := files[f.Path]
+		require.True(t, ok, "%s is missing from the bundle", f.Path)
+		assert.Equal(t, f.SizeBytes, int64(len(body)), f.Path)
+		sum := sha256.Sum256(body)
+		assert.Equal(t, hex.EncodeToString(sum[:]), f.SHA256, f.Path)
+	}
+	assert.Len(t, listed, len(order)-1)
+
+	doneDir := "analyses/" + done.ID.String() + "/"
+	failedDir := "analyses/" + failed.ID.String() + "/"
+	for _, p := range []string{"tenant.json", doneDir + "analysis.json", doneDir + "ingestion.json",
+		doneDir + "annotations.json", doneDir + "events.json", doneDir + "summary.json", doneDir + "sections/aggregates.json",
+		doneDir + "log_entries.ndjson.gz", failedDir + "analysis.json", failedDir + "annotations.json", failedDir + "events.json"} {
+		assert.True(t, listed[p], "%s not in manifest", p)
+	}
+	assert.False(t, listed[doneDir+"sections/gaps.json"], "uncached sections are left out")
+	assert.False(t, listed[failedDir+"summary.json"], "incomplete analyses have no summary")
+	assert.False(t, listed[failedDir+"log_entries.ndjson.gz"], "analyses without entries have no entries file")
+
+	for _, f := range manifest.Files {
+		switch f.Path {
+		case doneDir + "log_entries.ndjson.gz":
+			assert.Equal(t, int64(3), f.Rows)
+			assert.Equal(t, f.Rows, ndjsonRows(t, files[f.Path]))
+		case doneDir + "annotations.json":
+			var events []domain.InvestigationEvent
+			require.NoError(t, json.Unmarshal(files[f.Path], &events))
+			assert.Equal(t, int64(len(events)), f.Rows)
+		case doneDir + "events.json":
+			var events []domain.JobEvent
+			require.NoError(t, json.Unmarshal(files[f.Path], &events))
+			assert.Equal(t, int64(2), f.Rows)
+			assert.Equal(t, domain.JobEventCompleted, events[1].Kind)
+		}
+	}
+
+	var ingestion bundleIngestion
+	require.NoError(t, json.Unmarshal(files[doneDir+"ingestion.json"], &ingestion))
+	assert.Equal(t, int64(5), ingestion.EntriesStored)
+
+	nats.AssertCalled(t, "PublishJobProgress", mock.Anything, tenantID, exportID, 100, string(domain.ExportStatusComplete),
+		"tenant export complete: 2 analyses")
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	s3.AssertExpectations(t)
+}
+
+func TestExporter_ProcessExport_TenantBundleWithoutEntries(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	ch := &testutil.MockClickHouseStore{}
+	s3 := &testutil.MockObjectStorage{}
+	nats := &testutil.MockNATSStreamer{}
+
+	tenant := domain.Tenant{ID: uuid.New()}
+	job := domain.AnalysisJob{ID: uuid.New(), TenantID: tenant.ID, Status: domain.JobStatusComplete}
+	export := domain.SearchExport{ID: uuid.New(), TenantID: tenant.ID, Format: domain.ExportFormatTenantBundle}
+	key := TenantBundleKey(tenant.ID.String(), export.ID.String())
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	pg.On("GetTenant", mock.Anything, tenant.ID).Return(&tenant, nil)
+	pg.On("ListJobs", mock.Anything, tenant.ID).Return([]domain.AnalysisJob{job}, nil)
+	pg.On("ListInvestigationEvents", mock.Anything, tenant.ID, job.ID).Return(nil, nil)
+	pg.On("ListJobEvents", mock.Anything, tenant.ID, job.ID).Return(nil, nil)
+	pg.On("UpdateSearchExportProgress", mock.Anything, mock.Anything, mock.Anything, 95, int64(1)).Return(nil)
+	ch.On("CountJobEntries", mock.Anything, mock.Anything, mock.Anything).Return(int64(10), nil)
+	ch.On("GetDashboardData", mock.Anything, mock.Anything, mock.Anything, bundleSummaryTopN).Return(&domain.DashboardData{}, nil)
+	s3.On("Upload", mock.Anything, key, mock.Anything, mock.AnythingOfType("int64")).Return(nil)
+	pg.On("CompleteSearchExport", mock.Anything, tenant.ID, export.ID, key, int64(1), mock.AnythingOfType("int64"),
+		mock.AnythingOfType("*domain.ArtifactProvenance")).Return(nil)
+
+	require.NoError(t, NewExporter(pg, ch, s3, nats, 0).ProcessExport(context.Background(), export))
+	ch.AssertNotCalled(t, "SearchEntries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	pg.AssertExpectations(t)
+}
+
+func TestExporter_ProcessExport_TenantBundleFailure(t *testing.T) {
+	pg := &testutil.MockPostgresStore{}
+	nats := &testutil.MockNATSStreamer{}
+	tenant := domain.Tenant{ID: uuid.New()}
+	export := domain.SearchExport{ID: uuid.New(), TenantID: tenant.ID, Format: domain.ExportFormatTenantBundle}
+
+	pg.On("UpdateSearchExportStatus", mock.Anything, tenant.ID, export.ID, domain.ExportStatusRunning, (*string)(nil)).Return(nil)
+	pg.On("UpdateSearchExportStatus", mock.Anything, tenant.ID, export.ID, domain.ExportStatusFailed, mock.Anything).Return(nil)
+	nats.On("PublishJobProgress", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	pg.On("GetTenant", mock.Anything, tenant.ID).Return(&tenant, nil)
+	pg.On("ListJobs", mock.Anything, tenant.ID).Return(nil, errors.New("connection reset"))
+
+	e := NewExporter(pg, &testutil.MockClickHouseStore{}, &testutil.MockObjectStorage{}, nats, 0)
+	err := e.ProcessExport(context.Background(), export)
+	assert.ErrorContains(t, err, "list analyses")
+	pg.AssertExpectations(t)
+}
+
+func TestTenantBundleKey(t *testing.T) {
+	assert.Equal(t, "tenants/t1/exports/e1.tar.gz", TenantBundleKey("t1", "e1"))
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/streaming"
+)
+
+// maxThresholdRuleName bounds the length of a rule name.
+const maxThresholdRuleName = 200
+
+// thresholdMetricScopes lists the scopes each metric may be evaluated over.
+// Gaps are only meaningful for server-wide or per-queue traffic: a quiet
+// form or log type says more about user activity than server health.
+var thresholdMetricScopes = map[domain.ThresholdMetric][]domain.ThresholdScope{
+	domain.ThresholdMetricErrorRate: {domain.ThresholdScopeGlobal, domain.ThresholdScopeForm, domain.ThresholdScopeQueue, domain.ThresholdScopeLogType},
+	domain.ThresholdMetricP95:       {domain.ThresholdScopeGlobal, domain.ThresholdScopeForm, domain.ThresholdScopeQueue, domain.ThresholdScopeLogType},
+	domain.ThresholdMetricAvg:       {domain.ThresholdScopeGlobal, domain.ThresholdScopeForm, domain.ThresholdScopeQueue, domain.ThresholdScopeLogType},
+	domain.ThresholdMetricMaxGap:    {domain.ThresholdScopeGlobal, domain.ThresholdScopeQueue},
+}
+
+// ValidateThresholdRule checks a rule before it is stored and fills in the
+// default severity.
+func ValidateThresholdRule(rule *domain.ThresholdRule) error {
+	if rule.Name == "" || len(rule.Name) > maxThresholdRuleName {
+		return fmt.Errorf("name is required and must be at most %d characters", maxThresholdRuleName)
+	}
+
+	scopes, ok := thresholdMetricScopes[rule.Metric]
+	if !ok {
+		return fmt.Errorf("metric must be one of error_rate, p95, avg, max_gap")
+	}
+	switch rule.Scope {
+	case domain.ThresholdScopeGlobal:
+		if rule.ScopeValue != "" {
+			return fmt.Errorf("scope_value must be empty for global scope")
+		}
+	case domain.ThresholdScopeForm, domain.ThresholdScopeQueue:
+		if rule.ScopeValue == "" {
+			return fmt.Errorf("scope_value is required for %s scope", rule.Scope)
+		}
+	case domain.ThresholdScopeLogType:
+		switch domain.LogType(rule.ScopeValue) {
+		case domain.LogTypeAPI, domain.LogTypeSQL, domain.LogTypeFilter, domain.LogTypeEscalation:
+		default:
+			return fmt.Errorf("scope_value must be one of API, SQL, FLTR, ESCL for log_type scope")
+		}
+	default:
+		return fmt.Errorf("scope must be one of global, form, queue, log_type")
+	}
+	if !containsScope(scopes, rule.Scope) {
+		return fmt.Errorf("metric %s cannot be evaluated over %s scope", rule.Metric, rule.Scope)
+	}
+
+	switch rule.Comparator {
+	case domain.ThresholdComparatorGT, domain.ThresholdComparatorGTE, domain.ThresholdComparatorLT, domain.ThresholdComparatorLTE:
+	default:
+		return fmt.Errorf("comparator must be one of gt, gte, lt, lte")
+	}
+
+	if rule.Value < 0 {
+		return fmt.Errorf("value must not be negative")
+	}
+	if rule.Metric == domain.ThresholdMetricErrorRate && rule.Value > 100 {
+		return fmt.Errorf("error_rate value is a percentage and must be at most 100")
+	}
+
+	switch rule.Severity {
+	case "":
+		rule.Severity = domain.ThresholdSeverityWarning
+	case domain.ThresholdSeverityInfo, domain.ThresholdSeverityWarning, domain.ThresholdSeverityCritical:
+	default:
+		return fmt.Errorf("severity must be one of info, warning, critical")
+	}
+	return nil
+}
+
+func containsScope(scopes []domain.ThresholdScope, scope domain.ThresholdScope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ThresholdScopeKey identifies the metric set a rule is evaluated against.
+func ThresholdScopeKey(scope domain.ThresholdScope, value string) string {
+	return string(scope) + ":" + value
+}
+
+// EvaluateRule returns the observed value of the rule's metric and whether
+// "observed <comparator> value" holds, i.e. whether the rule is violated.
+func EvaluateRule(rule domain.ThresholdRule, m domain.ThresholdMetrics) (float64, bool) {
+	var observed float64
+	switch rule.Metric {
+	case domain.ThresholdMetricErrorRate:
+		observed = m.ErrorRate
+	case domain.ThresholdMetricP95:
+		observed = m.P95MS
+	case domain.ThresholdMetricAvg:
+		observed = m.AvgMS
+	case domain.ThresholdMetricMaxGap:
+		observed = m.MaxGapMS
+	default:
+		return 0, false
+	}
+
+	switch rule.Comparator {
+	case domain.ThresholdComparatorGT:
+		return observed, observed > rule.Value
+	case domain.ThresholdComparatorGTE:
+		return observed, observed >= rule.Value
+	case domain.ThresholdComparatorLT:
+		return observed, observed < rule.Value
+	case domain.ThresholdComparatorLTE:
+		return observed, observed <= rule.Value
+	}
+	return observed, false
+}
+
+// EvaluateThresholds evaluates the enabled rules against metrics keyed by
+// ThresholdScopeKey. Rules whose scope matched no entries are skipped: a
+// form that never appeared in the log cannot breach its SLO.
+func EvaluateThresholds(rules []domain.ThresholdRule, metrics map[string]domain.ThresholdMetrics) []domain.ThresholdViolation {
+	violations := []domain.ThresholdViolation{}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		m, ok := metrics[ThresholdScopeKey(rule.Scope, rule.ScopeValue)]
+		if !ok || m.EntryCount == 0 {
+			continue
+		}
+		observed, violated := EvaluateRule(rule, m)
+		if !violated {
+			continue
+		}
+		violations = append(violations, domain.ThresholdViolation{
+			RuleID:     rule.ID,
+			RuleName:   rule.Name,
+			Scope:      rule.Scope,
+			ScopeValue: rule.ScopeValue,
+			Metric:     rule.Metric,
+			Comparator: rule.Comparator,
+			Threshold:  rule.Value,
+			Observed:   observed,
+			Severity:   rule.Severity,
+		})
+	}
+	return violations
+}
+
+// ThresholdEvaluator checks a completed job against its tenant's threshold
+// rules, records the violations and announces critical ones.
+type ThresholdEvaluator struct {
+	pg   storage.PostgresStore
+	ch   storage.ClickHouseStore
+	nats streaming.NATSStreamer
+}
+
+// NewThresholdEvaluator creates a ThresholdEvaluator.
+func NewThresholdEvaluator(pg storage.PostgresStore, ch storage.ClickHouseStore, nats streaming.NATSStreamer) *ThresholdEvaluator {
+	return &ThresholdEvaluator{pg: pg, ch: ch, nats: nats}
+}
+
+// Evaluate runs the tenant's enabled rules against the job's entries in
+// ClickHouse. It returns nil when the tenant has no enabled rules, and
+// otherwise the (possibly empty) list of violations it recorded.
+func (e *ThresholdEvaluator) Evaluate(ctx context.Context, job domain.AnalysisJob) ([]domain.ThresholdViolation, error) {
+	ctx = storage.WithTenant(ctx, job.TenantID.String())
+	rules, err := e.pg.ListThresholdRules(ctx, job.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list threshold rules: %w", err)
+	}
+
+	enabled := rules[:0:0]
+	for _, r := range rules {
+		if r.Enabled {
+			enabled = append(enabled, r)
+		}
+	}
+	if len(enabled) == 0 {
+		return nil, nil
+	}
+
+	tenantID, jobID := job.TenantID.String(), job.ID.String()
+	metrics := make(map[string]domain.ThresholdMetrics)
+	for _, r := range enabled {
+		key := ThresholdScopeKey(r.Scope, r.ScopeValue)
+		if _, done := metrics[key]; done {
+			continue
+		}
+		m, err := e.ch.GetThresholdMetrics(ctx, tenantID, jobID, r.Scope, r.ScopeValue)
+		if err != nil {
+			return nil, fmt.Errorf("threshold metrics for %s: %w", key, err)
+		}
+		metrics[key] = *m
+	}
+
+	violations := EvaluateThresholds(enabled, metrics)
+	if err := e.pg.ReplaceJobViolations(ctx, job.TenantID, job.ID, violations); err != nil {
+		return nil, fmt.Errorf("record violations: %w", err)
+	}
+
+	for _, v := range violations {
+		if v.Severity != domain.ThresholdSeverityCritical {
+			continue
+		}
+		if err := e.nats.PublishThresholdViolation(ctx, tenantID, v); err != nil {
+			slog.Warn("failed to publish threshold violation",
+				"job_id", jobID, "rule_id", v.RuleID.String(), "error", err)
+		}
+	}
+	return violations, nil
+}
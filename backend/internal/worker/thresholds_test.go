@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func thresholdRule(metric domain.ThresholdMetric, cmp domain.ThresholdComparator, value float64) domain.ThresholdRule {
+	return domain.ThresholdRule{
+		ID:         uuid.New(),
+		Name:       string(metric) + " " + string(cmp),
+		Scope:      domain.ThresholdScopeGlobal,
+		Metric:     metric,
+		Comparator: cmp,
+		Value:      value,
+		Severity:   domain.ThresholdSeverityWarning,
+		Enabled:    true,
+	}
+}
+
+func TestEvaluateRule_Comparators(t *testing.T) {
+	m := domain.ThresholdMetrics{EntryCount: 10, P95MS: 500}
+
+	tests := []struct {
+		cmp   domain.ThresholdComparator
+		value float64
+		want  bool
+	}{
+		{domain.ThresholdComparatorGT, 499, true},
+		{domain.ThresholdComparatorGT, 500, false},
+		{domain.ThresholdComparatorGTE, 500, true},
+		{domain.ThresholdComparatorGTE, 501, false},
+		{domain.ThresholdComparatorLT, 501, true},
+		{domain.ThresholdComparatorLT, 500, false},
+		{domain.ThresholdComparatorLTE, 500, true},
+		{domain.ThresholdComparatorLTE, 499, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.cmp), func(t *testing.T) {
+			observed, violated := EvaluateRule(thresholdRule(domain.ThresholdMetricP95, tc.cmp, tc.value), m)
+			assert.Equal(t, 500.0, observed)
+			assert.Equal(t, tc.want, violated)
+		})
+	}
+}
+
+func TestEvaluateRule_Metrics(t *testing.T) {
+	m := domain.ThresholdMetrics{EntryCount: 10, ErrorRate: 12.5, P95MS: 900, AvgMS: 120, MaxGapMS: 45000}
+
+	tests := []struct {
+		metric domain.ThresholdMetric
+		want   float64
+	}{
+		{domain.ThresholdMetricErrorRate, 12.5},
+		{domain.ThresholdMetricP95, 900},
+		{domain.ThresholdMetricAvg, 120},
+		{domain.ThresholdMetricMaxGap, 45000},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.metric), func(t *testing.T) {
+			observed, violated := EvaluateRule(thresholdRule(tc.metric, domain.ThresholdComparatorGT, 0), m)
+			assert.Equal(t, tc.want, observed)
+			assert.True(t, violated)
+		})
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	global := ThresholdScopeKey(domain.ThresholdScopeGlobal, "")
+	formKey := ThresholdScopeKey(domain.ThresholdScopeForm, "HPD:Help Desk")
+
+	breach := thresholdRule(domain.ThresholdMetricErrorRate, domain.ThresholdComparatorGT, 5)
+	ok := thresholdRule(domain.ThresholdMetricAvg, domain.ThresholdComparatorGT, 1000)
+	disabled := thresholdRule(domain.ThresholdMetricErrorRate, domain.ThresholdComparatorGT, 1)
+	disabled.Enabled = false
+	unseenForm := thresholdRule(domain.ThresholdMetricP95, domain.ThresholdComparatorGT, 0)
+	unseenForm.Scope, unseenForm.ScopeValue = domain.ThresholdScopeForm, "HPD:Help Desk"
+
+	metrics := map[string]domain.ThresholdMetrics{
+		global:  {EntryCount: 100, ErrorRate: 8, AvgMS: 200},
+		formKey: {EntryCount: 0},
+	}
+
+	violations := EvaluateThresholds([]domain.ThresholdRule{breach, ok, disabled, unseenForm}, metrics)
+	require.Len(t, violations, 1)
+	v := violations[0]
+	assert.Equal(t, breach.ID, v.RuleID)
+	assert.Equal(t, 8.0, v.Observed)
+	assert.Equal(t, 5.0, v.Threshold)
+	assert.Equal(t, domain.ThresholdSeverityWarning, v.Severity)
+}
+
+func TestEvaluateThresholds_NoRules(t *testing.T) {
+	violations := EvaluateThresholds(nil, nil)
+	assert.NotNil(t, violations)
+	assert.Empty(t, violations)
+}
+
+func TestValidateThresholdRule(t *testing.T) {
+	valid := func() *domain.ThresholdRule {
+		r := thresholdRule(domain.ThresholdMetricP95, domain.ThresholdComparatorGT, 2000)
+		r.Scope, r.ScopeValue = domain.ThresholdScopeForm, "HPD:Help Desk"
+		r.Severity = ""
+		return &r
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(r *domain.ThresholdRule)
+		wantErr string
+	}{
+		{"valid form rule", func(r *domain.ThresholdRule) {}, ""},
+		{"missing name", func(r *domain.ThresholdRule) { r.Name = "" }, "name is required"},
+		{"unknown metric", func(r *domain.ThresholdRule) { r.Metric = "p99" }, "metric must be one of"},
+		{"unknown scope", func(r *domain.ThresholdRule) { r.Scope = "user" }, "scope must be one of"},
+		{"global with value", func(r *domain.ThresholdRule) { r.Scope = domain.ThresholdScopeGlobal }, "must be empty"},
+		{"form without value", func(r *domain.ThresholdRule) { r.ScopeValue = "" }, "scope_value is required"},
+		{"bad log type", func(r *domain.ThresholdRule) { r.Scope, r.ScopeValue = domain.ThresholdScopeLogType, "HTTP" }, "API, SQL, FLTR, ESCL"},
+		{"max_gap on form", func(r *domain.ThresholdRule) { r.Metric = domain.ThresholdMetricMaxGap }, "cannot be evaluated over form scope"},
+		{"max_gap on queue", func(r *domain.ThresholdRule) {
+			r.Metric, r.Scope, r.ScopeValue = domain.ThresholdMetricMaxGap, domain.ThresholdScopeQueue, "Fast"
+		}, ""},
+		{"unknown comparator", func(r *domain.ThresholdRule) { r.Comparator = "eq" }, "comparator must be one of"},
+		{"negative value", func(r *domain.ThresholdRule) { r.Value = -1 }, "must not be negative"},
+		{"error rate above 100", func(r *domain.ThresholdRule) {
+			r.Metric, r.Value = domain.ThresholdMetricErrorRate, 101
+		}, "at most 100"},
+		{"unknown severity", func(r *domain.ThresholdRule) { r.Severity = "fatal" }, "severity must be one of"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := valid()
+			tc.mutate(r)
+			err := ValidateThresholdRule(r)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, domain.ThresholdSeverityWarning, r.Severity)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestThresholdEvaluator_NoRules(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	nc := new(testutil.MockNATSStreamer)
+
+	job := domain.AnalysisJob{ID: uuid.New(), TenantID: uuid.New()}
+	disabled := thresholdRule(domain.ThresholdMetricAvg, domain.ThresholdComparatorGT, 1)
+	disabled.Enabled = false
+	pg.On("ListThresholdRules", mock.Anything, job.TenantID).Return([]domain.ThresholdRule{disabled}, nil)
+
+	violations, err := NewThresholdEvaluator(pg, ch, nc).Evaluate(context.Background(), job)
+	require.NoError(t, err)
+	assert.Nil(t, violations)
+
+	pg.AssertExpectations(t)
+	pg.AssertNotCalled(t, "ReplaceJobViolations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	ch.AssertNotCalled(t, "GetThresholdMetrics", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestThresholdEvaluator_RecordsAndPublishesCritical(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	nc := new(testutil.MockNATSStreamer)
+
+	job := domain.AnalysisJob{ID: uuid.New(), TenantID: uuid.New()}
+	tenantID, jobID := job.TenantID.String(), job.ID.String()
+
+	critical := thresholdRule(domain.ThresholdMetricErrorRate, domain.ThresholdComparatorGTE, 5)
+	critical.Severity = domain.ThresholdSeverityCritical
+	warning := thresholdRule(domain.ThresholdMetricP95, domain.ThresholdComparatorGT, 1000)
+	queue := thresholdRule(domain.ThresholdMetricMaxGap, domain.ThresholdComparatorGT, 60000)
+	queue.Scope, queue.ScopeValue = domain.ThresholdScopeQueue, "Fast"
+
+	pg.On("ListThresholdRules", mock.Anything, job.TenantID).
+		Return([]domain.ThresholdRule{critical, warning, queue}, nil)
+	// The two global rules share a single metrics query.
+	ch.On("GetThresholdMetrics", mock.Anything, tenantID, jobID, domain.ThresholdScopeGlobal, "").
+		Return(&domain.ThresholdMetrics{EntryCount: 50, ErrorRate: 5, P95MS: 1500}, nil).Once()
+	ch.On("GetThresholdMetrics", mock.Anything, tenantID, jobID, domain.ThresholdScopeQueue, "Fast").
+		Return(&domain.ThresholdMetrics{EntryCount: 20, MaxGapMS: 1000}, nil).Once()
+	pg.On("ReplaceJobViolations", mock.Anything, job.TenantID, job.ID,
+		mock.MatchedBy(func(v []domain.ThresholdViolation) bool { return len(v) == 2 })).Return(nil)
+	nc.On("PublishThresholdViolation", mock.Anything, tenantID,
+		mock.MatchedBy(func(v domain.ThresholdViolation) bool { return v.RuleID == critical.ID })).Return(nil).Once()
+
+	violations, err := NewThresholdEvaluator(pg, ch, nc).Evaluate(context.Background(), job)
+	require.NoError(t, err)
+	assert.Len(t, violations, 2)
+
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	nc.AssertExpectations(t)
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/ticketing"
+)
+
+const (
+	// ticketSyncStaleAfter is how old the synced status of a link gets
+	// before it is looked up again.
+	ticketSyncStaleAfter = time.Hour
+
+	// ticketSyncBatch caps the links looked up in one run.
+	ticketSyncBatch = 200
+)
+
+// TicketSyncer refreshes the status of analyses' Jira and ServiceNow links
+// through their tenants' integrations, so that analysis lists show it
+// without calling the external systems.
+type TicketSyncer struct {
+	pg      storage.PostgresStore
+	keyring *ticketing.Keyring
+	http    *http.Client
+}
+
+// NewTicketSyncer creates a TicketSyncer. httpClient may be nil.
+func NewTicketSyncer(pg storage.PostgresStore, keyring *ticketing.Keyring, httpClient *http.Client) *TicketSyncer {
+	return &TicketSyncer{pg: pg, keyring: keyring, http: httpClient}
+}
+
+// integrationKey names the integration of a tenant with one system.
+type integrationKey struct {
+	tenantID uuid.UUID
+	typ      domain.AnalysisLinkType
+}
+
+// Run looks up the links whose status is stale at now and returns how many
+// were refreshed. A link whose record cannot be looked up, e.g. because
+// the system is unreachable or the tenant has no enabled integration, gets
+// status unknown until a later run succeeds.
+func (s *TicketSyncer) Run(ctx context.Context, now time.Time) (int, error) {
+	links, err := s.pg.ListAnalysisLinksToSync(ctx, now.Add(-ticketSyncStaleAfter), ticketSyncBatch)
+	if err != nil {
+		return 0, fmt.Errorf("list analysis links to sync: %w", err)
+	}
+
+	clients := make(map[integrationKey]ticketing.Client)
+	refreshed := 0
+	for _, l := range links {
+		if ctx.Err() != nil {
+			return refreshed, ctx.Err()
+		}
+		key := integrationKey{l.TenantID, l.Type}
+		client, seen := clients[key]
+		if !seen {
+			client = s.client(ctx, key)
+			clients[key] = client
+		}
+
+		status, summary := domain.LinkStatusUnknown, l.Summary
+		if client != nil {
+			rec, err := client.Lookup(ctx, l.ExternalKey)
+			if err != nil {
+				slog.Warn("ticket status sync failed", "tenant_id", l.TenantID.String(), "key", l.ExternalKey, "error", err)
+			} else {
+				status, summary = rec.Status, rec.Summary
+				refreshed++
+			}
+		}
+		if err := s.pg.UpdateAnalysisLinkStatus(ctx, l.TenantID, l.ID, status, summary, now); err != nil {
+			slog.Warn("failed to record ticket status", "link_id", l.ID.String(), "error", err)
+		}
+	}
+	return refreshed, nil
+}
+
+// client returns the client of an enabled integration, nil without one.
+func (s *TicketSyncer) client(ctx context.Context, key integrationKey) ticketing.Client {
+	integration, err := s.pg.GetTicketingIntegration(ctx, key.tenantID, key.typ)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			slog.Warn("failed to retrieve ticketing integration", "tenant_id", key.tenantID.String(), "type", key.typ, "error", err)
+		}
+		return nil
+	}
+	if !integration.Enabled {
+		return nil
+	}
+	client, err := ticketing.NewClient(integration, s.keyring, s.http)
+	if err != nil {
+		slog.Warn("failed to open ticketing integration", "tenant_id", key.tenantID.String(), "type", key.typ, "error", err)
+		return nil
+	}
+	return client
+}
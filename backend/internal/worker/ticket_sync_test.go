@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/ticketing"
+)
+
+func TestTicketSyncer_Run(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	keyring, err := ticketing.NewKeyring(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	require.NoError(t, err)
+
+	snow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("sysparm_query") {
+		case "number=INC0012345":
+			_, _ = w.Write([]byte(`{"result":[{"number":"INC0012345","state":"Resolved","short_description":"Email down"}]}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer snow.Close()
+
+	withSNOW := uuid.New()
+	withoutIntegration := uuid.New()
+	sealed, err := keyring.Seal([]byte("token"), ticketing.TokenAAD(withSNOW, domain.AnalysisLinkServiceNow))
+	require.NoError(t, err)
+
+	resolved := domain.AnalysisLink{ID: uuid.New(), TenantID: withSNOW, Type: domain.AnalysisLinkServiceNow, ExternalKey: "INC0012345"}
+	failing := domain.AnalysisLink{ID: uuid.New(), TenantID: withSNOW, Type: domain.AnalysisLinkServiceNow, ExternalKey: "INC0099999", Summary: "Old summary"}
+	orphan := domain.AnalysisLink{ID: uuid.New(), TenantID: withoutIntegration, Type: domain.AnalysisLinkJira, ExternalKey: "PROJ-1"}
+
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListAnalysisLinksToSync", mock.Anything, now.Add(-ticketSyncStaleAfter), ticketSyncBatch).
+		Return([]domain.AnalysisLink{resolved, failing, orphan}, nil)
+	pg.On("GetTicketingIntegration", mock.Anything, withSNOW, domain.AnalysisLinkServiceNow).
+		Return(&domain.TicketingIntegration{TenantID: withSNOW, Type: domain.AnalysisLinkServiceNow, BaseURL: snow.URL, Username: "svc", Sealed: sealed, Enabled: true}, nil).Once()
+	pg.On("GetTicketingIntegration", mock.Anything, withoutIntegration, domain.AnalysisLinkJira).
+		Return(nil, errors.New("postgres: ticketing integration not found: jira")).Once()
+	pg.On("UpdateAnalysisLinkStatus", mock.Anything, withSNOW, resolved.ID, "Resolved", "Email down", now).Return(nil)
+	pg.On("UpdateAnalysisLinkStatus", mock.Anything, withSNOW, failing.ID, domain.LinkStatusUnknown, "Old summary", now).Return(nil)
+	pg.On("UpdateAnalysisLinkStatus", mock.Anything, withoutIntegration, orphan.ID, domain.LinkStatusUnknown, "", now).Return(nil)
+
+	n, err := NewTicketSyncer(pg, keyring, snow.Client()).Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	pg.AssertExpectations(t)
+}
+
+func TestTicketSyncer_ListFails(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListAnalysisLinksToSync", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	_, err := NewTicketSyncer(pg, nil, nil).Run(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "list analysis links to sync")
+}
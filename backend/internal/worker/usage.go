@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+)
+
+// UsageReconciler backfills usage events that were lost before the usage
+// recorder wrote them, e.g. on a crash or a full buffer. The expected events
+// are derived from the tables that are the source of truth for each metric
+// and compared with the recorded ones.
+type UsageReconciler struct {
+	pg storage.PostgresStore
+	ch storage.ClickHouseStore
+}
+
+// NewUsageReconciler creates a UsageReconciler.
+func NewUsageReconciler(pg storage.PostgresStore, ch storage.ClickHouseStore) *UsageReconciler {
+	return &UsageReconciler{pg: pg, ch: ch}
+}
+
+// Run reconciles the usage of the current and the previous calendar month
+// as of now and returns the number of events backfilled. Recorded amounts
+// that differ from their source are reported but left unchanged.
+func (r *UsageReconciler) Run(ctx context.Context, now time.Time) (int, error) {
+	now = now.UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	expected, err := r.pg.ListUsageSources(ctx, since)
+	if err != nil {
+		return 0, fmt.Errorf("list usage sources: %w", err)
+	}
+	recorded, err := r.pg.ListUsageEvents(ctx, since)
+	if err != nil {
+		return 0, fmt.Errorf("list usage events: %w", err)
+	}
+	expected = append(expected, r.storedRows(ctx, expected, recorded)...)
+
+	diff := diffUsage(expected, recorded)
+	for _, d := range diff.drifted {
+		slog.Warn("recorded usage differs from source",
+			"tenant_id", d.expected.TenantID.String(),
+			"metric", d.expected.Metric,
+			"source_id", d.expected.SourceID.String(),
+			"source_amount", d.expected.Amount,
+			"recorded_amount", d.recorded,
+		)
+	}
+	if len(diff.missing) == 0 {
+		return 0, nil
+	}
+
+	// Recording is idempotent, so events recorded concurrently since the
+	// listing are not counted twice.
+	n, err := r.pg.RecordUsageEvents(ctx, diff.missing)
+	if err != nil {
+		return n, fmt.Errorf("backfill usage events: %w", err)
+	}
+	return n, nil
+}
+
+// storedRows returns the rows_stored events of completed jobs that have
+// none recorded, counting the rows in ClickHouse. Jobs whose count fails
+// are retried on the next run.
+func (r *UsageReconciler) storedRows(ctx context.Context, expected, recorded []domain.UsageEvent) []domain.UsageEvent {
+	have := make(map[usageKey]bool)
+	for _, e := range recorded {
+		if e.Metric == domain.UsageRowsStored {
+			have[keyOf(e)] = true
+		}
+	}
+
+	var out []domain.UsageEvent
+	for _, e := range expected {
+		if e.Metric != domain.UsageAnalysesRun {
+			continue
+		}
+		rows := domain.UsageEvent{
+			TenantID:   e.TenantID,
+			Metric:     domain.UsageRowsStored,
+			SourceID:   e.SourceID,
+			OccurredAt: e.OccurredAt,
+		}
+		if have[keyOf(rows)] {
+			continue
+		}
+		n, err := r.ch.CountJobEntries(storage.WithTenant(ctx, e.TenantID.String()), e.TenantID.String(), e.SourceID.String())
+		if err != nil {
+			slog.Warn("failed to count stored rows", "job_id", e.SourceID.String(), "error", err)
+			continue
+		}
+		rows.Amount = n
+		out = append(out, rows)
+	}
+	return out
+}
+
+// usageKey identifies a usage event the way usage_events does.
+type usageKey struct {
+	tenantID uuid.UUID
+	metric   domain.UsageMetric
+	sourceID uuid.UUID
+}
+
+func keyOf(e domain.UsageEvent) usageKey {
+	return usageKey{tenantID: e.TenantID, metric: e.Metric, sourceID: e.SourceID}
+}
+
+// usageDrift is an event recorded with an amount other than its source's.
+type usageDrift struct {
+	expected domain.UsageEvent
+	recorded int64
+}
+
+// usageDiff is the difference between the expected and recorded events.
+type usageDiff struct {
+	missing []domain.UsageEvent
+	drifted []usageDrift
+}
+
+// diffUsage compares the events derived from the source tables with the
+// recorded ones. Recorded events without a source, such as usage of rows
+// since deleted, are not part of the diff.
+func diffUsage(expected, recorded []domain.UsageEvent) usageDiff {
+	amounts := make(map[usageKey]int64, len(recorded))
+	for _, e := range recorded {
+		amounts[keyOf(e)] = e.Amount
+	}
+
+	var diff usageDiff
+	for _, e := range expected {
+		amount, ok := amounts[keyOf(e)]
+		switch {
+		case !ok:
+			diff.missing = append(diff.missing, e)
+		case amount != e.Amount:
+			diff.drifted = append(diff.drifted, usageDrift{expected: e, recorded: amount})
+		}
+	}
+	return diff
+}
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestDiffUsage(t *testing.T) {
+	tenant := uuid.New()
+	at := time.Date(2026, 10, 3, 9, 0, 0, 0, time.UTC)
+	ev := func(metric domain.UsageMetric, source uuid.UUID, amount int64) domain.UsageEvent {
+		return domain.UsageEvent{TenantID: tenant, Metric: metric, SourceID: source, Amount: amount, OccurredAt: at}
+	}
+	file, job, msg, deleted := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name        string
+		expected    []domain.UsageEvent
+		recorded    []domain.UsageEvent
+		wantMissing []domain.UsageEvent
+		wantDrifted []usageDrift
+	}{
+		{
+			name:     "nothing recorded",
+			expected: []domain.UsageEvent{ev(domain.UsageBytesUploaded, file, 4096), ev(domain.UsageAnalysesRun, job, 1)},
+			wantMissing: []domain.UsageEvent{
+				ev(domain.UsageBytesUploaded, file, 4096), ev(domain.UsageAnalysesRun, job, 1),
+			},
+		},
+		{
+			name:     "all recorded",
+			expected: []domain.UsageEvent{ev(domain.UsageBytesUploaded, file, 4096)},
+			recorded: []domain.UsageEvent{ev(domain.UsageBytesUploaded, file, 4096)},
+		},
+		{
+			name:        "same source under another metric is missing",
+			expected:    []domain.UsageEvent{ev(domain.UsageAnalysesRun, job, 1), ev(domain.UsageWorkerMS, job, 5300)},
+			recorded:    []domain.UsageEvent{ev(domain.UsageAnalysesRun, job, 1)},
+			wantMissing: []domain.UsageEvent{ev(domain.UsageWorkerMS, job, 5300)},
+		},
+		{
+			name:        "amount differs",
+			expected:    []domain.UsageEvent{ev(domain.UsageWorkerMS, job, 5300)},
+			recorded:    []domain.UsageEvent{ev(domain.UsageWorkerMS, job, 5200)},
+			wantDrifted: []usageDrift{{expected: ev(domain.UsageWorkerMS, job, 5300), recorded: 5200}},
+		},
+		{
+			name:     "recorded without source is ignored",
+			expected: []domain.UsageEvent{ev(domain.UsageAIQueries, msg, 1)},
+			recorded: []domain.UsageEvent{ev(domain.UsageAIQueries, msg, 1), ev(domain.UsageBytesUploaded, deleted, 10)},
+		},
+		{
+			name:        "other tenant does not match",
+			expected:    []domain.UsageEvent{ev(domain.UsageAIQueries, msg, 1)},
+			recorded:    []domain.UsageEvent{{TenantID: uuid.New(), Metric: domain.UsageAIQueries, SourceID: msg, Amount: 1}},
+			wantMissing: []domain.UsageEvent{ev(domain.UsageAIQueries, msg, 1)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := diffUsage(tc.expected, tc.recorded)
+			assert.Equal(t, tc.wantMissing, diff.missing)
+			assert.Equal(t, tc.wantDrifted, diff.drifted)
+		})
+	}
+}
+
+func TestUsageReconciler_Run(t *testing.T) {
+	now := time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)
+	since := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	tenant := uuid.New()
+	file, done, counted, failing := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	at := now.Add(-time.Hour)
+	ev := func(metric domain.UsageMetric, source uuid.UUID, amount int64) domain.UsageEvent {
+		return domain.UsageEvent{TenantID: tenant, Metric: metric, SourceID: source, Amount: amount, OccurredAt: at}
+	}
+
+	pg := new(testutil.MockPostgresStore)
+	ch := new(testutil.MockClickHouseStore)
+	pg.On("ListUsageSources", mock.Anything, since).Return([]domain.UsageEvent{
+		ev(domain.UsageBytesUploaded, file, 4096),
+		ev(domain.UsageAnalysesRun, done, 1),
+		ev(domain.UsageAnalysesRun, counted, 1),
+		ev(domain.UsageAnalysesRun, failing, 1),
+		ev(domain.UsageWorkerMS, done, 5300),
+	}, nil)
+	pg.On("ListUsageEvents", mock.Anything, since).Return([]domain.UsageEvent{
+		ev(domain.UsageAnalysesRun, done, 1),
+		ev(domain.UsageRowsStored, done, 870),
+		ev(domain.UsageWorkerMS, done, 5000),
+		ev(domain.UsageAnalysesRun, counted, 1),
+	}, nil)
+	ch.On("CountJobEntries", mock.Anything, tenant.String(), counted.String()).Return(int64(1200), nil)
+	ch.On("CountJobEntries", mock.Anything, tenant.String(), failing.String()).Return(int64(0), errors.New("timeout"))
+	pg.On("RecordUsageEvents", mock.Anything, []domain.UsageEvent{
+		ev(domain.UsageBytesUploaded, file, 4096),
+		ev(domain.UsageAnalysesRun, failing, 1),
+		ev(domain.UsageRowsStored, counted, 1200),
+	}).Return(3, nil)
+
+	n, err := NewUsageReconciler(pg, ch).Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	pg.AssertExpectations(t)
+	ch.AssertExpectations(t)
+	ch.AssertNotCalled(t, "CountJobEntries", mock.Anything, tenant.String(), done.String())
+}
+
+func TestUsageReconciler_NothingMissing(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	since := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	event := domain.UsageEvent{TenantID: uuid.New(), Metric: domain.UsageAIQueries, SourceID: uuid.New(), Amount: 1}
+
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListUsageSources", mock.Anything, since).Return([]domain.UsageEvent{event}, nil)
+	pg.On("ListUsageEvents", mock.Anything, since).Return([]domain.UsageEvent{event}, nil)
+
+	n, err := NewUsageReconciler(pg, new(testutil.MockClickHouseStore)).Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	pg.AssertNotCalled(t, "RecordUsageEvents", mock.Anything, mock.Anything)
+}
+
+func TestUsageReconciler_SourcesFail(t *testing.T) {
+	pg := new(testutil.MockPostgresStore)
+	pg.On("ListUsageSources", mock.Anything, mock.Anything).Return(nil, errors.New("connection reset"))
+
+	_, err := NewUsageReconciler(pg, new(testutil.MockClickHouseStore)).Run(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "list usage sources")
+	pg.AssertNotCalled(t, "ListUsageEvents", mock.Anything, mock.Anything)
+}
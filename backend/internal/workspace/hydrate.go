@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
+)
+
+// snippetChars caps the snippet of a pinned entry.
+const snippetChars = 200
+
+// EntrySummaries reads the summaries of entries of a job.
+// storage.ClickHouseStore implements it.
+type EntrySummaries interface {
+	GetEntrySummaries(ctx context.Context, tenantID, jobID string, entryIDs []string) ([]domain.LogEntry, error)
+}
+
+// Hydrate returns ws, whose state is state, with a summary of each pinned
+// entry, read in one query, and whether each query still parses. Pins of
+// entries the analysis no longer holds are returned with Found false. When
+// the entries cannot be read the view is returned without pins, the reason
+// in Unavailable.
+func Hydrate(ctx context.Context, entries EntrySummaries, ws domain.Workspace, state domain.WorkspaceState) *domain.WorkspaceView {
+	view := &domain.WorkspaceView{Workspace: ws, Queries: make([]domain.WorkspaceQueryStatus, len(state.Queries))}
+
+	for i, q := range state.Queries {
+		status := domain.WorkspaceQueryStatus{Query: q.Query, Parses: true}
+		if _, err := search.ParseKQL(q.Query); err != nil {
+			status.Parses, status.Error = false, err.Error()
+		}
+		view.Queries[i] = status
+	}
+
+	view.Pins = make([]domain.WorkspacePin, len(state.PinnedEntries))
+	if len(state.PinnedEntries) == 0 {
+		return view
+	}
+	found, err := entries.GetEntrySummaries(ctx, ws.TenantID.String(), ws.JobID.String(), state.PinnedEntries)
+	if err != nil {
+		slog.Warn("workspace pins not available", "workspace_id", ws.ID, "job_id", ws.JobID, "error", err)
+		view.Pins = nil
+		view.Unavailable = map[string]string{"pins": "entries could not be read"}
+		return view
+	}
+	byID := make(map[string]*domain.LogEntry, len(found))
+	for i := range found {
+		byID[found[i].EntryID] = &found[i]
+	}
+	for i, id := range state.PinnedEntries {
+		view.Pins[i] = pinOf(id, byID[id])
+	}
+	return view
+}
+
+// pinOf summarises the pinned entry id; e is nil when it was not found.
+func pinOf(id string, e *domain.LogEntry) domain.WorkspacePin {
+	pin := domain.WorkspacePin{EntryID: id}
+	if e == nil {
+		return pin
+	}
+	success := e.Success
+	pin.Found = true
+	pin.Timestamp = &e.Timestamp
+	pin.LogType = e.LogType
+	pin.LineNumber = e.LineNumber
+	pin.Form = e.Form
+	pin.Queue = e.Queue
+	pin.DurationMS = e.DurationMS
+	pin.Success = &success
+	pin.Snippet = snippet(e)
+	return pin
+}
+
+// snippet is the start of the entry's error message, or of its raw text
+// without one, on one line.
+func snippet(e *domain.LogEntry) string {
+	text := e.ErrorMessage
+	if strings.TrimSpace(text) == "" {
+		text = e.RawText
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= snippetChars {
+		return text
+	}
+	return string([]rune(text)[:snippetChars]) + "..."
+}
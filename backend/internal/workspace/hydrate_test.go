@@ -0,0 +1,86 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+)
+
+func TestHydrate(t *testing.T) {
+	ws := domain.Workspace{ID: uuid.New(), TenantID: uuid.New(), JobID: uuid.New(), Name: "war room"}
+	ts := domain.NewTimestamp(time.Date(2026, 3, 2, 9, 12, 0, 0, time.UTC))
+	state := domain.WorkspaceState{
+		PinnedEntries: []string{"e1", "gone", "e2"},
+		Queries: []domain.WorkspaceQuery{
+			{Name: "slow", Query: "duration_ms:>2000"},
+			{Query: "type:API AND (user:Demo"},
+		},
+	}
+
+	t.Run("pins and queries", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetEntrySummaries", mock.Anything, ws.TenantID.String(), ws.JobID.String(), []string{"e1", "gone", "e2"}).Return([]domain.LogEntry{
+			{EntryID: "e2", Timestamp: ts, LogType: domain.LogTypeSQL, LineNumber: 40, RawText: "SELECT   *\nFROM T1", Success: true},
+			{EntryID: "e1", Timestamp: ts, LogType: domain.LogTypeAPI, LineNumber: 12, Form: "HPD:Help Desk", RawText: "+GE ...", ErrorMessage: "ARERR 302 Entry does not exist"},
+		}, nil).Once()
+
+		view := Hydrate(context.Background(), ch, ws, state)
+
+		require.Len(t, view.Pins, 3)
+		assert.Equal(t, "e1", view.Pins[0].EntryID)
+		assert.True(t, view.Pins[0].Found)
+		assert.Equal(t, "HPD:Help Desk", view.Pins[0].Form)
+		assert.Equal(t, "ARERR 302 Entry does not exist", view.Pins[0].Snippet)
+		assert.False(t, *view.Pins[0].Success)
+
+		assert.Equal(t, domain.WorkspacePin{EntryID: "gone"}, view.Pins[1], "entries no longer held are kept, not found")
+
+		assert.True(t, view.Pins[2].Found)
+		assert.Equal(t, "SELECT * FROM T1", view.Pins[2].Snippet)
+		assert.Equal(t, uint32(40), view.Pins[2].LineNumber)
+
+		require.Len(t, view.Queries, 2)
+		assert.True(t, view.Queries[0].Parses)
+		assert.False(t, view.Queries[1].Parses)
+		assert.Equal(t, "missing closing parenthesis", view.Queries[1].Error)
+		assert.Nil(t, view.Unavailable)
+		assert.Equal(t, ws.Name, view.Name)
+		ch.AssertExpectations(t)
+	})
+
+	t.Run("entries unavailable", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		ch.On("GetEntrySummaries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("clickhouse down"))
+
+		view := Hydrate(context.Background(), ch, ws, state)
+
+		assert.Nil(t, view.Pins)
+		assert.Contains(t, view.Unavailable, "pins")
+		assert.Len(t, view.Queries, 2, "queries are checked without ClickHouse")
+	})
+
+	t.Run("nothing pinned", func(t *testing.T) {
+		ch := new(testutil.MockClickHouseStore)
+		view := Hydrate(context.Background(), ch, ws, domain.WorkspaceState{})
+		assert.Equal(t, []domain.WorkspacePin{}, view.Pins)
+		ch.AssertNotCalled(t, "GetEntrySummaries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("é", snippetChars+10)
+	s := snippet(&domain.LogEntry{RawText: long})
+	assert.Equal(t, snippetChars+3, len([]rune(s)))
+	assert.True(t, strings.HasSuffix(s, "..."))
+	assert.Equal(t, "raw", snippet(&domain.LogEntry{RawText: "raw", ErrorMessage: "  "}))
+}
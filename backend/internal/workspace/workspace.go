@@ -0,0 +1,234 @@
+// Package workspace holds the state document of investigation workspaces:
+// its versioned schema, the upgrade of documents stored at older versions,
+// and the hydration of the entries and queries a workspace references.
+package workspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/search"
+)
+
+// SchemaVersion is the current layout of the state document. Documents are
+// always stored at the current version; those written at an older one are
+// upgraded when read or submitted.
+const SchemaVersion = 1
+
+const (
+	// MaxNameLength caps the name of a workspace and of its queries.
+	MaxNameLength = 120
+	// MaxStateBytes caps the compacted state document.
+	MaxStateBytes = 128 * 1024
+
+	maxPinnedEntries   = 100
+	maxEntryIDLength   = 64
+	maxQueries         = 20
+	maxQueryLength     = 4096
+	maxSelections      = 50
+	maxSelectionLength = 255
+	maxNotesBytes      = 64 * 1024
+)
+
+// upgrades[v] rewrites a state document of schema version v into the
+// layout of version v+1. A new schema version adds its upgrade here.
+var upgrades = map[int]func(doc map[string]any) (map[string]any, error){}
+
+// Problem is one problem of a state document, at the JSON path of the
+// offending value, such as "queries[2].query".
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the problems of a state document.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Path + ": " + p.Message
+	}
+	return "invalid workspace state: " + strings.Join(msgs, "; ")
+}
+
+// Decode parses a state document written at schema version version,
+// upgrading it to SchemaVersion. Fields the schema does not know are
+// rejected. An empty document is the empty state.
+func Decode(version int, raw json.RawMessage) (domain.WorkspaceState, error) {
+	var state domain.WorkspaceState
+	if version < 1 || version > SchemaVersion {
+		return state, fmt.Errorf("schema_version must be between 1 and %d", SchemaVersion)
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		raw = json.RawMessage(`{}`)
+	}
+
+	if version < SchemaVersion {
+		var doc map[string]any
+		if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+			return state, fmt.Errorf("state must be a JSON object")
+		}
+		for v := version; v < SchemaVersion; v++ {
+			upgrade, ok := upgrades[v]
+			if !ok {
+				return state, fmt.Errorf("no upgrade of schema version %d", v)
+			}
+			var err error
+			if doc, err = upgrade(doc); err != nil {
+				return state, fmt.Errorf("upgrade schema version %d: %w", v, err)
+			}
+		}
+		upgraded, err := json.Marshal(doc)
+		if err != nil {
+			return state, fmt.Errorf("encode upgraded state: %w", err)
+		}
+		raw = upgraded
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&state); err != nil {
+		return state, fmt.Errorf("state does not match schema version %d: %w", SchemaVersion, err)
+	}
+	if dec.More() {
+		return state, fmt.Errorf("state must be a single JSON object")
+	}
+	return state, nil
+}
+
+// Encode returns the stored form of a state document.
+func Encode(state domain.WorkspaceState) (json.RawMessage, error) {
+	normalize(&state)
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("encode workspace state: %w", err)
+	}
+	if len(raw) > MaxStateBytes {
+		return nil, &ValidationError{Problems: []Problem{{Path: "state", Message: fmt.Sprintf("exceeds %d bytes", MaxStateBytes)}}}
+	}
+	return raw, nil
+}
+
+// Load upgrades ws's state to SchemaVersion in place, for workspaces
+// stored before the current layout, and returns the state.
+func Load(ws *domain.Workspace) (domain.WorkspaceState, error) {
+	state, err := Decode(ws.SchemaVersion, ws.State)
+	if err != nil {
+		return state, err
+	}
+	if ws.SchemaVersion != SchemaVersion {
+		raw, err := Encode(state)
+		if err != nil {
+			return state, err
+		}
+		ws.State, ws.SchemaVersion = raw, SchemaVersion
+	}
+	return state, nil
+}
+
+// Validate checks state against the limits of the schema, trimming its
+// values and dropping repeated pins, forms and queues. Queries are not
+// required to parse: the grammar may change under a stored query, so
+// hydration reports whether each one still does.
+func Validate(state *domain.WorkspaceState) error {
+	var problems []Problem
+	add := func(path, format string, args ...any) {
+		problems = append(problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	normalize(state)
+
+	if len(state.PinnedEntries) > maxPinnedEntries {
+		add("pinned_entries", "at most %d entries can be pinned", maxPinnedEntries)
+	}
+	for i, id := range state.PinnedEntries {
+		if id == "" || len(id) > maxEntryIDLength {
+			add(fmt.Sprintf("pinned_entries[%d]", i), "must be an entry ID of 1 to %d characters", maxEntryIDLength)
+		}
+	}
+
+	if len(state.Queries) > maxQueries {
+		add("queries", "at most %d queries can be kept", maxQueries)
+	}
+	for i, q := range state.Queries {
+		path := fmt.Sprintf("queries[%d]", i)
+		if q.Query == "" {
+			add(path+".query", "is required")
+		} else if len(q.Query) > maxQueryLength {
+			add(path+".query", "exceeds %d characters", maxQueryLength)
+		}
+		if utf8.RuneCountInString(q.Name) > MaxNameLength {
+			add(path+".name", "exceeds %d characters", MaxNameLength)
+		}
+	}
+
+	if w := state.TimeWindow; w != nil {
+		if _, err := search.ParseTimeExpr(w.From); err != nil {
+			add("time_window.from", "%v", err)
+		}
+		if _, err := search.ParseTimeExpr(w.To); err != nil {
+			add("time_window.to", "%v", err)
+		}
+	}
+
+	for _, sel := range []struct {
+		path   string
+		values []string
+	}{{"forms", state.Forms}, {"queues", state.Queues}} {
+		if len(sel.values) > maxSelections {
+			add(sel.path, "at most %d can be selected", maxSelections)
+		}
+		for i, v := range sel.values {
+			if v == "" || len(v) > maxSelectionLength {
+				add(fmt.Sprintf("%s[%d]", sel.path, i), "must be a name of 1 to %d characters", maxSelectionLength)
+			}
+		}
+	}
+
+	if len(state.Notes) > maxNotesBytes {
+		add("notes", "exceed %d bytes", maxNotesBytes)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// normalize trims the values of state, drops repeated pins, forms and
+// queues, and replaces missing lists with empty ones.
+func normalize(state *domain.WorkspaceState) {
+	state.PinnedEntries = uniqueTrimmed(state.PinnedEntries)
+	state.Forms = uniqueTrimmed(state.Forms)
+	state.Queues = uniqueTrimmed(state.Queues)
+	if state.Queries == nil {
+		state.Queries = []domain.WorkspaceQuery{}
+	}
+	for i := range state.Queries {
+		state.Queries[i].Name = strings.TrimSpace(state.Queries[i].Name)
+		state.Queries[i].Query = strings.TrimSpace(state.Queries[i].Query)
+	}
+	if w := state.TimeWindow; w != nil {
+		w.From, w.To = strings.TrimSpace(w.From), strings.TrimSpace(w.To)
+	}
+}
+
+func uniqueTrimmed(values []string) []string {
+	out := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if seen[v] && v != "" {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
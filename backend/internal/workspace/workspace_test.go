@@ -0,0 +1,172 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+func TestDecode(t *testing.T) {
+	t.Run("current schema", func(t *testing.T) {
+		state, err := Decode(SchemaVersion, json.RawMessage(`{
+			"pinned_entries": ["e1", "e2"],
+			"queries": [{"name": "slow", "query": "duration_ms:>2000"}],
+			"time_window": {"from": "capture_end-15m", "to": "capture_end"},
+			"forms": ["HPD:Help Desk"],
+			"queues": ["Fast"],
+			"notes": "started at 09:12"
+		}`))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"e1", "e2"}, state.PinnedEntries)
+		assert.Equal(t, "duration_ms:>2000", state.Queries[0].Query)
+		assert.Equal(t, "capture_end-15m", state.TimeWindow.From)
+		assert.Equal(t, "started at 09:12", state.Notes)
+	})
+
+	t.Run("empty document", func(t *testing.T) {
+		state, err := Decode(SchemaVersion, nil)
+		require.NoError(t, err)
+		assert.Empty(t, state.PinnedEntries)
+	})
+
+	tests := []struct {
+		name    string
+		version int
+		raw     string
+		wantErr string
+	}{
+		{"unknown field", SchemaVersion, `{"pins": ["e1"]}`, `unknown field "pins"`},
+		{"wrong type", SchemaVersion, `{"pinned_entries": "e1"}`, "does not match schema version"},
+		{"not an object", SchemaVersion, `["e1"]`, "does not match schema version"},
+		{"trailing document", SchemaVersion, `{} {}`, "single JSON object"},
+		{"version too new", SchemaVersion + 1, `{}`, "schema_version must be between 1 and 1"},
+		{"version zero", 0, `{}`, "schema_version must be between 1 and 1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decode(tc.version, json.RawMessage(tc.raw))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	many := func(n int, v string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s%d", v, i)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		state     domain.WorkspaceState
+		wantPaths []string
+	}{
+		{
+			name: "valid",
+			state: domain.WorkspaceState{
+				PinnedEntries: []string{"e1"},
+				Queries:       []domain.WorkspaceQuery{{Query: "type:API"}},
+				TimeWindow:    &domain.WorkspaceTimeWindow{From: "2026-03-02T08:00:00Z", To: "capture_end"},
+				Forms:         []string{"HPD:Help Desk"},
+			},
+		},
+		{
+			name:  "queries need not parse",
+			state: domain.WorkspaceState{Queries: []domain.WorkspaceQuery{{Query: "type:API AND (user:Demo"}}},
+		},
+		{
+			name:      "too many pins",
+			state:     domain.WorkspaceState{PinnedEntries: many(maxPinnedEntries+1, "e")},
+			wantPaths: []string{"pinned_entries"},
+		},
+		{
+			name:      "empty pin",
+			state:     domain.WorkspaceState{PinnedEntries: []string{"e1", " "}},
+			wantPaths: []string{"pinned_entries[1]"},
+		},
+		{
+			name:      "empty query",
+			state:     domain.WorkspaceState{Queries: []domain.WorkspaceQuery{{Name: "slow"}}},
+			wantPaths: []string{"queries[0].query"},
+		},
+		{
+			name:      "query too long",
+			state:     domain.WorkspaceState{Queries: []domain.WorkspaceQuery{{Query: strings.Repeat("a", maxQueryLength+1)}}},
+			wantPaths: []string{"queries[0].query"},
+		},
+		{
+			name:      "bad time window",
+			state:     domain.WorkspaceState{TimeWindow: &domain.WorkspaceTimeWindow{From: "yesterday", To: ""}},
+			wantPaths: []string{"time_window.from", "time_window.to"},
+		},
+		{
+			name:      "too many queues",
+			state:     domain.WorkspaceState{Queues: many(maxSelections+1, "q")},
+			wantPaths: []string{"queues"},
+		},
+		{
+			name:      "notes too long",
+			state:     domain.WorkspaceState{Notes: strings.Repeat("n", maxNotesBytes+1)},
+			wantPaths: []string{"notes"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(&tc.state)
+			if tc.wantPaths == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var invalid *ValidationError
+			require.ErrorAs(t, err, &invalid)
+			var paths []string
+			for _, p := range invalid.Problems {
+				paths = append(paths, p.Path)
+			}
+			assert.Equal(t, tc.wantPaths, paths)
+		})
+	}
+}
+
+func TestValidate_Normalizes(t *testing.T) {
+	state := domain.WorkspaceState{
+		PinnedEntries: []string{" e1", "e2", "e1 "},
+		Queries:       []domain.WorkspaceQuery{{Name: " slow ", Query: " duration_ms:>2000 "}},
+		Forms:         []string{"HPD:Help Desk", "HPD:Help Desk"},
+	}
+	require.NoError(t, Validate(&state))
+	assert.Equal(t, []string{"e1", "e2"}, state.PinnedEntries)
+	assert.Equal(t, domain.WorkspaceQuery{Name: "slow", Query: "duration_ms:>2000"}, state.Queries[0])
+	assert.Equal(t, []string{"HPD:Help Desk"}, state.Forms)
+	assert.Equal(t, []string{}, state.Queues)
+}
+
+func TestEncode(t *testing.T) {
+	raw, err := Encode(domain.WorkspaceState{PinnedEntries: []string{"e1"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"pinned_entries":["e1"],"queries":[],"forms":[],"queues":[],"notes":""}`, string(raw))
+
+	_, err = Encode(domain.WorkspaceState{Notes: strings.Repeat("n", MaxStateBytes)})
+	var invalid *ValidationError
+	assert.ErrorAs(t, err, &invalid)
+}
+
+func TestLoad(t *testing.T) {
+	ws := &domain.Workspace{SchemaVersion: SchemaVersion, State: json.RawMessage(`{"pinned_entries":["e1"]}`)}
+	state, err := Load(ws)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e1"}, state.PinnedEntries)
+
+	_, err = Load(&domain.Workspace{SchemaVersion: SchemaVersion + 1, State: json.RawMessage(`{}`)})
+	assert.Error(t, err)
+}
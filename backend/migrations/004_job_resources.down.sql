@@ -0,0 +1,7 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 004_job_resources (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS wall_time_ms,
+    DROP COLUMN IF EXISTS cpu_time_ms,
+    DROP COLUMN IF EXISTS peak_rss_kb;
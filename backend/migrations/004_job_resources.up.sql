@@ -0,0 +1,12 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 004_job_resources
+-- Records the resources consumed by the JAR process of each analysis job
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS peak_rss_kb  BIGINT,
+    ADD COLUMN IF NOT EXISTS cpu_time_ms  BIGINT,
+    ADD COLUMN IF NOT EXISTS wall_time_ms BIGINT;
+
+COMMENT ON COLUMN analysis_jobs.peak_rss_kb IS 'Peak resident set size of the JAR process in KiB';
+COMMENT ON COLUMN analysis_jobs.cpu_time_ms IS 'User + system CPU time of the JAR process in milliseconds';
+COMMENT ON COLUMN analysis_jobs.wall_time_ms IS 'Wall-clock run time of the JAR process in milliseconds';
@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 005_search_exports (rollback)
+
+DROP TABLE IF EXISTS search_exports;
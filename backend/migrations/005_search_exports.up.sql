@@ -0,0 +1,37 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 005_search_exports
+-- Adds search_exports for asynchronous search result exports to S3
+
+CREATE TABLE IF NOT EXISTS search_exports (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id          UUID NOT NULL REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'queued'
+                    CHECK (status IN ('queued', 'running', 'complete', 'failed', 'expired')),
+    format          TEXT NOT NULL CHECK (format IN ('csv', 'json')),
+    query           JSONB NOT NULL DEFAULT '{}',
+    progress_pct    INTEGER NOT NULL DEFAULT 0,
+    row_count       BIGINT NOT NULL DEFAULT 0,
+    size_bytes      BIGINT NOT NULL DEFAULT 0,
+    s3_key          TEXT,
+    error_message   TEXT,
+    expires_at      TIMESTAMPTZ NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_exports_tenant_created ON search_exports(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_search_exports_expiry ON search_exports(expires_at) WHERE status <> 'expired';
+
+ALTER TABLE search_exports ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'search_exports') THEN
+        CREATE POLICY tenant_isolation ON search_exports
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
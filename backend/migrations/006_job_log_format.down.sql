@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 006_job_log_format (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS log_format;
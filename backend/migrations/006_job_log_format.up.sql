@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 006_job_log_format
+-- Records the AR Server log format detected before the JAR runs
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS log_format VARCHAR(20);
+
+COMMENT ON COLUMN analysis_jobs.log_format IS 'Detected log layout of the input file (ar-25.x, ar-20.x, ar-9.x, unknown)';
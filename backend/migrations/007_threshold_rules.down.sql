@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 007_threshold_rules (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS violation_count;
+
+DROP TABLE IF EXISTS threshold_violations;
+DROP TABLE IF EXISTS threshold_rules;
@@ -0,0 +1,60 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 007_threshold_rules
+-- Adds tenant-defined threshold rules and the violations recorded per job
+
+CREATE TABLE IF NOT EXISTS threshold_rules (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    scope           TEXT NOT NULL CHECK (scope IN ('global', 'form', 'queue', 'log_type')),
+    scope_value     TEXT NOT NULL DEFAULT '',
+    metric          TEXT NOT NULL CHECK (metric IN ('error_rate', 'p95', 'avg', 'max_gap')),
+    comparator      TEXT NOT NULL CHECK (comparator IN ('gt', 'gte', 'lt', 'lte')),
+    value           DOUBLE PRECISION NOT NULL,
+    severity        TEXT NOT NULL DEFAULT 'warning' CHECK (severity IN ('info', 'warning', 'critical')),
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_threshold_rules_tenant ON threshold_rules(tenant_id, created_at);
+
+CREATE TABLE IF NOT EXISTS threshold_violations (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id          UUID NOT NULL REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    rule_id         UUID NOT NULL,
+    rule_name       TEXT NOT NULL,
+    scope           TEXT NOT NULL,
+    scope_value     TEXT NOT NULL DEFAULT '',
+    metric          TEXT NOT NULL,
+    comparator      TEXT NOT NULL,
+    threshold       DOUBLE PRECISION NOT NULL,
+    observed        DOUBLE PRECISION NOT NULL,
+    severity        TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_threshold_violations_job ON threshold_violations(tenant_id, job_id);
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS violation_count INTEGER;
+
+COMMENT ON COLUMN analysis_jobs.violation_count IS 'Threshold rule violations recorded when the job completed';
+
+ALTER TABLE threshold_rules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE threshold_violations ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'threshold_rules') THEN
+        CREATE POLICY tenant_isolation ON threshold_rules
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'threshold_violations') THEN
+        CREATE POLICY tenant_isolation ON threshold_violations
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 008_digest_subscriptions (rollback)
+
+DROP TABLE IF EXISTS digest_subscriptions;
@@ -0,0 +1,27 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 008_digest_subscriptions
+-- Adds per-tenant opt-in for the daily analysis digest
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    tenant_id        UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    enabled          BOOLEAN NOT NULL DEFAULT TRUE,
+    recipients       TEXT[] NOT NULL DEFAULT '{}',
+    send_hour        SMALLINT NOT NULL DEFAULT 7 CHECK (send_hour BETWEEN 0 AND 23),
+    timezone         TEXT NOT NULL DEFAULT 'UTC',
+    last_period_end  TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN digest_subscriptions.last_period_end IS 'End of the last local day a digest was produced for';
+
+ALTER TABLE digest_subscriptions ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'digest_subscriptions') THEN
+        CREATE POLICY tenant_isolation ON digest_subscriptions
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,6 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 009_job_clock_skew (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS clock_skew,
+    DROP COLUMN IF EXISTS correct_clock_skew;
@@ -0,0 +1,10 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 009_job_clock_skew
+-- Clock skew between the files of a multi-file capture
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS correct_clock_skew BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS clock_skew JSONB;
+
+COMMENT ON COLUMN analysis_jobs.correct_clock_skew IS 'Shift timestamps of skewed files by the estimated offset at insert time';
+COMMENT ON COLUMN analysis_jobs.clock_skew IS 'Files whose clock differs from the reference file beyond the threshold';
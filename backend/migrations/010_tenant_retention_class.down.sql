@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 010_tenant_retention_class (rollback)
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS retention_class;
@@ -0,0 +1,11 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 010_tenant_retention_class
+-- Retention class applied to each tenant's ClickHouse log entries
+
+-- Existing rows start as 'standard', matching the ClickHouse column default,
+-- so the retention reconciler restamps tenants whose plan maps elsewhere.
+ALTER TABLE tenants
+    ADD COLUMN IF NOT EXISTS retention_class VARCHAR(20) NOT NULL DEFAULT 'standard'
+        CHECK (retention_class IN ('trial', 'standard', 'enterprise'));
+
+COMMENT ON COLUMN tenants.retention_class IS 'Retention class last applied to the tenant''s log entries; trails plan until reconciled';
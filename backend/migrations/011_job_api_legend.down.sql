@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 011_job_api_legend (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS api_legend;
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 011_job_api_legend
+-- API abbreviation legend printed by the JAR for each analysis
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS api_legend JSONB;
+
+COMMENT ON COLUMN analysis_jobs.api_legend IS 'API abbreviation legend (abbreviation -> full name) from the JAR output';
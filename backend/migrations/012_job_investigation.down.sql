@@ -0,0 +1,14 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 012_job_investigation (rollback)
+
+DROP TABLE IF EXISTS investigation_events;
+
+DROP INDEX IF EXISTS idx_jobs_investigation_assignee;
+DROP INDEX IF EXISTS idx_jobs_investigation_status;
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS investigation_updated_at,
+    DROP COLUMN IF EXISTS investigation_version,
+    DROP COLUMN IF EXISTS investigation_assignee,
+    DROP COLUMN IF EXISTS investigation_notes,
+    DROP COLUMN IF EXISTS investigation_status;
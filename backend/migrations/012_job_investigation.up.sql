@@ -0,0 +1,46 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 012_job_investigation
+-- Investigation workflow on analyses: status, notes, assignee and the
+-- history of changes
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS investigation_status TEXT NOT NULL DEFAULT 'new'
+        CHECK (investigation_status IN ('new', 'investigating', 'root_cause_identified', 'resolved', 'archived')),
+    ADD COLUMN IF NOT EXISTS investigation_notes TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS investigation_assignee TEXT,
+    ADD COLUMN IF NOT EXISTS investigation_version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS investigation_updated_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN analysis_jobs.investigation_notes IS 'Investigation findings as markdown';
+COMMENT ON COLUMN analysis_jobs.investigation_assignee IS 'User ID of the tenant member working the investigation';
+COMMENT ON COLUMN analysis_jobs.investigation_version IS 'Incremented on every investigation update; used as the optimistic concurrency precondition';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_investigation_status ON analysis_jobs(tenant_id, investigation_status);
+CREATE INDEX IF NOT EXISTS idx_jobs_investigation_assignee ON analysis_jobs(tenant_id, investigation_assignee)
+    WHERE investigation_assignee IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS investigation_events (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id          UUID NOT NULL REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    actor_id        TEXT NOT NULL,
+    from_status     TEXT NOT NULL,
+    to_status       TEXT NOT NULL,
+    assignee        TEXT,
+    notes_changed   BOOLEAN NOT NULL DEFAULT FALSE,
+    version         INTEGER NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_investigation_events_job ON investigation_events(tenant_id, job_id, created_at);
+
+ALTER TABLE investigation_events ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'investigation_events') THEN
+        CREATE POLICY tenant_isolation ON investigation_events
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 013_health_profiles (rollback)
+
+DROP TABLE IF EXISTS health_profiles;
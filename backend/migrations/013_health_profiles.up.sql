@@ -0,0 +1,26 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 013_health_profiles
+-- Per-tenant health score profiles. Every edit adds a new version so that
+-- scores computed with an older version stay interpretable.
+
+CREATE TABLE IF NOT EXISTS health_profiles (
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    version     INTEGER NOT NULL CHECK (version > 0),
+    config      JSONB NOT NULL,
+    created_by  TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, version)
+);
+
+COMMENT ON COLUMN health_profiles.config IS 'Factor weights, breakpoints and status thresholds; version 0 is the built-in default and is never stored';
+
+ALTER TABLE health_profiles ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'health_profiles') THEN
+        CREATE POLICY tenant_isolation ON health_profiles
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
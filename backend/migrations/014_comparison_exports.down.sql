@@ -0,0 +1,7 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 014_comparison_exports (rollback)
+
+DELETE FROM search_exports WHERE format IN ('comparison_html', 'comparison_zip');
+ALTER TABLE search_exports DROP CONSTRAINT IF EXISTS search_exports_format_check;
+ALTER TABLE search_exports ADD CONSTRAINT search_exports_format_check
+    CHECK (format IN ('csv', 'json'));
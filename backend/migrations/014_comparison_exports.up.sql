@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 014_comparison_exports
+-- Allows search_exports to hold analysis comparison reports. For these the
+-- job_id is the candidate analysis and the query holds both job IDs.
+
+ALTER TABLE search_exports DROP CONSTRAINT IF EXISTS search_exports_format_check;
+ALTER TABLE search_exports ADD CONSTRAINT search_exports_format_check
+    CHECK (format IN ('csv', 'json', 'comparison_html', 'comparison_zip'));
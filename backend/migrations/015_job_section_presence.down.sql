@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 015_job_section_presence (rollback)
+
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS section_presence;
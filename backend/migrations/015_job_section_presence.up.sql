@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 015_job_section_presence
+-- Which log types a capture holds, for skipping and hiding empty sections
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS section_presence JSONB;
+
+COMMENT ON COLUMN analysis_jobs.section_presence IS 'Per log type JAR and ingested counts, presence and count discrepancy warnings';
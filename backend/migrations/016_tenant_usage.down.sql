@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 016_tenant_usage (rollback)
+
+DROP TABLE IF EXISTS tenant_usage;
+DROP TABLE IF EXISTS usage_events;
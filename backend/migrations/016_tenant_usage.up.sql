@@ -0,0 +1,50 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 016_tenant_usage
+-- Per-tenant usage accounting for billing and capacity planning. Every
+-- accounted quantity is an event keyed by the operation it was measured on;
+-- recording an event adds its amount to the monthly counter exactly once.
+
+CREATE TABLE IF NOT EXISTS usage_events (
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    metric      TEXT NOT NULL
+                CHECK (metric IN ('bytes_uploaded', 'analyses_run', 'rows_stored', 'worker_ms', 'ai_queries')),
+    source_id   UUID NOT NULL,
+    amount      BIGINT NOT NULL CHECK (amount >= 0),
+    occurred_at TIMESTAMPTZ NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, metric, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_events_occurred ON usage_events(occurred_at);
+
+COMMENT ON COLUMN usage_events.source_id IS 'File, job or message the amount was measured on';
+
+CREATE TABLE IF NOT EXISTS tenant_usage (
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    month       DATE NOT NULL,
+    metric      TEXT NOT NULL,
+    value       BIGINT NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, month, metric)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenant_usage_month ON tenant_usage(month);
+
+COMMENT ON COLUMN tenant_usage.month IS 'First day of the calendar month (UTC)';
+
+ALTER TABLE usage_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tenant_usage ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'usage_events') THEN
+        CREATE POLICY tenant_isolation ON usage_events
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'tenant_usage') THEN
+        CREATE POLICY tenant_isolation ON tenant_usage
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 017_job_parse_diagnostics (rollback)
+
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS parse_diagnostics;
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 017_job_parse_diagnostics
+-- Diagnostics of strict JAR report parsing, for spotting report format changes
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS parse_diagnostics JSONB;
+
+COMMENT ON COLUMN analysis_jobs.parse_diagnostics IS 'Skipped sections, unreadable rows and per-table row coverage of a strict parse of the JAR report';
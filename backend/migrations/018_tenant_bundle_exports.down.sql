@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 018_tenant_bundle_exports (rollback)
+
+DELETE FROM search_exports WHERE format = 'tenant_bundle';
+ALTER TABLE search_exports DROP CONSTRAINT IF EXISTS search_exports_format_check;
+ALTER TABLE search_exports ADD CONSTRAINT search_exports_format_check
+    CHECK (format IN ('csv', 'json', 'comparison_html', 'comparison_zip'));
+ALTER TABLE search_exports ALTER COLUMN job_id SET NOT NULL;
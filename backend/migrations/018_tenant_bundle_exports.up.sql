@@ -0,0 +1,9 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 018_tenant_bundle_exports
+-- Allows search_exports to hold tenant bundles, which export every analysis
+-- of a tenant and so have no job_id.
+
+ALTER TABLE search_exports ALTER COLUMN job_id DROP NOT NULL;
+ALTER TABLE search_exports DROP CONSTRAINT IF EXISTS search_exports_format_check;
+ALTER TABLE search_exports ADD CONSTRAINT search_exports_format_check
+    CHECK (format IN ('csv', 'json', 'comparison_html', 'comparison_zip', 'tenant_bundle'));
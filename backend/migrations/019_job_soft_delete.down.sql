@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 019_job_soft_delete (rollback)
+
+DROP INDEX IF EXISTS idx_jobs_deleted_at;
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,11 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 019_job_soft_delete
+-- Deleted analyses go to the trash first and are purged after a grace period
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_deleted_at ON analysis_jobs(deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+COMMENT ON COLUMN analysis_jobs.deleted_at IS 'When the analysis was moved to the trash; NULL for live analyses';
@@ -0,0 +1,6 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 020_job_error_onset (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS first_error_at,
+    DROP COLUMN IF EXISTS error_onset;
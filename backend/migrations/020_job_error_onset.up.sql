@@ -0,0 +1,10 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 020_job_error_onset
+-- When a capture started to fail, for incident reviews
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS error_onset JSONB,
+    ADD COLUMN IF NOT EXISTS first_error_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN analysis_jobs.error_onset IS 'First failed entry per log type, form and queue, cumulative error curve and error rate threshold crossings';
+COMMENT ON COLUMN analysis_jobs.first_error_at IS 'Timestamp of the earliest failed entry; NULL when the capture has no errors';
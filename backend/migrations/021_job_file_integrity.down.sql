@@ -0,0 +1,6 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 021_job_file_integrity (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS error_code,
+    DROP COLUMN IF EXISTS file_integrity;
@@ -0,0 +1,10 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 021_job_file_integrity
+-- Pre-flight integrity check of uploaded log files
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS file_integrity JSONB,
+    ADD COLUMN IF NOT EXISTS error_code TEXT;
+
+COMMENT ON COLUMN analysis_jobs.file_integrity IS 'Integrity issues found in the uploaded file before analysis, with byte offsets';
+COMMENT ON COLUMN analysis_jobs.error_code IS 'Machine-readable reason a job failed, e.g. binary_content; NULL otherwise';
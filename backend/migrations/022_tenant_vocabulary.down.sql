@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 022_tenant_vocabulary (rollback)
+
+DROP TABLE IF EXISTS tenant_vocabulary;
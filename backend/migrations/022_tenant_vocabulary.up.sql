@@ -0,0 +1,37 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 022_tenant_vocabulary
+-- Form, filter, table, queue and escalation names seen across the analyses
+-- of a tenant, for typeahead outside a single analysis. Values unseen for a
+-- while are evicted by the worker.
+
+CREATE TABLE IF NOT EXISTS tenant_vocabulary (
+    tenant_id     UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    field         TEXT NOT NULL
+                  CHECK (field IN ('form', 'filter_name', 'sql_table', 'queue', 'esc_name')),
+    value         TEXT NOT NULL,
+    occurrences   BIGINT NOT NULL DEFAULT 0,
+    job_count     BIGINT NOT NULL DEFAULT 0,
+    last_job_id   UUID NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, field, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenant_vocabulary_prefix
+    ON tenant_vocabulary(tenant_id, field, lower(value) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_tenant_vocabulary_last_seen
+    ON tenant_vocabulary(tenant_id, field, last_seen_at);
+
+COMMENT ON COLUMN tenant_vocabulary.occurrences IS 'Log entries holding the value, summed over analyses';
+COMMENT ON COLUMN tenant_vocabulary.last_job_id IS 'Most recent analysis the value was seen in';
+
+ALTER TABLE tenant_vocabulary ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'tenant_vocabulary') THEN
+        CREATE POLICY tenant_isolation ON tenant_vocabulary
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
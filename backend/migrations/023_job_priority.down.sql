@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 023_job_priority (rollback)
+
+DROP INDEX IF EXISTS idx_analysis_jobs_queue;
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS priority;
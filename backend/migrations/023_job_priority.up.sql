@@ -0,0 +1,15 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 023_job_priority
+-- Job priority levels and the processing start time used for queue estimates
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal'
+        CHECK (priority IN ('interactive', 'normal', 'batch')),
+    ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_queue
+    ON analysis_jobs (status, priority, created_at)
+    WHERE deleted_at IS NULL;
+
+COMMENT ON COLUMN analysis_jobs.priority IS 'Dispatch priority: interactive, normal or batch; defaults by file size';
+COMMENT ON COLUMN analysis_jobs.started_at IS 'When a worker started processing the job; with completed_at it gives the per-MB rate behind queue estimates';
@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 024_support_access (rollback)
+
+DROP TABLE IF EXISTS support_access_events;
+DROP TABLE IF EXISTS support_access_grants;
@@ -0,0 +1,55 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 024_support_access
+-- Time-boxed grants by which a tenant lets platform support act in it, and
+-- the audit of every request support made under them.
+
+CREATE TABLE IF NOT EXISTS support_access_grants (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    granted_by  TEXT NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    read_only   BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL,
+    revoked_at  TIMESTAMPTZ,
+    revoked_by  TEXT,
+    CHECK (expires_at > created_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_support_access_grants_tenant
+    ON support_access_grants(tenant_id, expires_at DESC);
+
+CREATE TABLE IF NOT EXISTS support_access_events (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id        UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    grant_id         UUID NOT NULL REFERENCES support_access_grants(id) ON DELETE CASCADE,
+    actor_user_id    TEXT NOT NULL,
+    actor_tenant_id  TEXT NOT NULL,
+    method           TEXT NOT NULL,
+    path             TEXT NOT NULL,
+    status           INTEGER NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_support_access_events_tenant
+    ON support_access_events(tenant_id, created_at DESC);
+
+COMMENT ON COLUMN support_access_events.tenant_id IS 'Tenant the support engineer acted in';
+COMMENT ON COLUMN support_access_events.actor_tenant_id IS 'Home tenant of the support engineer';
+
+ALTER TABLE support_access_grants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE support_access_events ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'support_access_grants') THEN
+        CREATE POLICY tenant_isolation ON support_access_grants
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'support_access_events') THEN
+        CREATE POLICY tenant_isolation ON support_access_events
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
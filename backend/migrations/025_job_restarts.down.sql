@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 025_job_restarts (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS restarts;
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 025_job_restarts
+-- AR Server restarts detected within a capture
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS restarts JSONB;
+
+COMMENT ON COLUMN analysis_jobs.restarts IS 'Server restarts detected while ingesting the capture, with their evidence';
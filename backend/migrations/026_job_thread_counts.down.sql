@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 026_job_thread_counts (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS thread_counts;
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 026_job_thread_counts
+-- Threads configured per AR Server queue, as the JAR preamble reports them
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS thread_counts JSONB;
+
+COMMENT ON COLUMN analysis_jobs.thread_counts IS 'Threads configured per queue, keyed by queue name, from the JAR preamble';
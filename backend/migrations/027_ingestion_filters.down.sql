@@ -0,0 +1,7 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 027_ingestion_filters (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS ingestion_filter_stats;
+
+DROP TABLE IF EXISTS ingestion_filter_rules;
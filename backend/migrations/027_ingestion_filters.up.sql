@@ -0,0 +1,35 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 027_ingestion_filters
+-- Adds tenant-defined ingestion filter rules and the entries they matched per job
+
+CREATE TABLE IF NOT EXISTS ingestion_filter_rules (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    field           TEXT NOT NULL,
+    operator        TEXT NOT NULL CHECK (operator IN ('equals', 'prefix', 'suffix', 'contains')),
+    value           TEXT NOT NULL,
+    case_sensitive  BOOLEAN NOT NULL DEFAULT FALSE,
+    action          TEXT NOT NULL DEFAULT 'drop' CHECK (action IN ('drop', 'flag')),
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingestion_filter_rules_tenant ON ingestion_filter_rules(tenant_id, created_at);
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS ingestion_filter_stats JSONB;
+
+COMMENT ON COLUMN analysis_jobs.ingestion_filter_stats IS 'Entries each ingestion filter rule dropped or flagged as noise';
+
+ALTER TABLE ingestion_filter_rules ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'ingestion_filter_rules') THEN
+        CREATE POLICY tenant_isolation ON ingestion_filter_rules
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
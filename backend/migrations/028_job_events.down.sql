@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 028_job_events (rollback)
+
+DROP TABLE IF EXISTS job_events;
@@ -0,0 +1,28 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 028_job_events
+-- Adds the per-job event log replayed to debug stuck or slow analyses
+
+CREATE TABLE IF NOT EXISTS job_events (
+    id          BIGSERIAL PRIMARY KEY,
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id      UUID NOT NULL REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    source      TEXT NOT NULL CHECK (source IN ('api', 'worker')),
+    kind        TEXT NOT NULL,
+    stage       TEXT NOT NULL DEFAULT '',
+    message     TEXT NOT NULL DEFAULT '',
+    metadata    JSONB,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_events_job ON job_events(job_id, occurred_at, id);
+
+ALTER TABLE job_events ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'job_events') THEN
+        CREATE POLICY tenant_isolation ON job_events
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 029_job_sampling (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS sampling;
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 029_job_sampling
+-- Sampled ingestion of captures too large to ingest in full
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS sampling JSONB;
+
+COMMENT ON COLUMN analysis_jobs.sampling IS 'Sample rate, slow entry threshold, full-fidelity hot windows and ingested entry counts; NULL when every entry is ingested';
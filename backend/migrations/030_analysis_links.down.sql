@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 030_analysis_links (rollback)
+
+DROP TABLE IF EXISTS analysis_links;
+DROP TABLE IF EXISTS ticketing_integrations;
@@ -0,0 +1,53 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 030_analysis_links
+-- Adds links from analyses to Jira issues, ServiceNow records and URLs, and
+-- the per-tenant ticketing integrations that validate and sync them
+
+CREATE TABLE IF NOT EXISTS ticketing_integrations (
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    type            TEXT NOT NULL CHECK (type IN ('jira', 'servicenow')),
+    base_url        TEXT NOT NULL,
+    username        TEXT NOT NULL DEFAULT '',
+    token_sealed    BYTEA NOT NULL,
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, type)
+);
+
+COMMENT ON COLUMN ticketing_integrations.token_sealed IS 'API token sealed in an envelope: encrypted with a data key that is itself encrypted with TICKETING_ENCRYPTION_KEY';
+
+CREATE TABLE IF NOT EXISTS analysis_links (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id        UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id           UUID NOT NULL REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    type             TEXT NOT NULL CHECK (type IN ('jira', 'servicenow', 'url')),
+    external_key     TEXT NOT NULL,
+    title            TEXT NOT NULL DEFAULT '',
+    status           TEXT NOT NULL DEFAULT '',
+    summary          TEXT NOT NULL DEFAULT '',
+    status_synced_at TIMESTAMPTZ,
+    created_by       TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (job_id, type, external_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_links_tenant_job ON analysis_links(tenant_id, job_id);
+CREATE INDEX IF NOT EXISTS idx_analysis_links_sync ON analysis_links(status_synced_at NULLS FIRST) WHERE type <> 'url';
+
+ALTER TABLE ticketing_integrations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE analysis_links ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'ticketing_integrations') THEN
+        CREATE POLICY tenant_isolation ON ticketing_integrations
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'analysis_links') THEN
+        CREATE POLICY tenant_isolation ON analysis_links
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
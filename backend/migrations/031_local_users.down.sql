@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 031_local_users (rollback)
+
+DROP TABLE IF EXISTS local_users;
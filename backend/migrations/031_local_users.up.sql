@@ -0,0 +1,20 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 031_local_users
+-- Adds the users of the local auth provider (AUTH_PROVIDER=local), for
+-- deployments that cannot reach Clerk
+
+CREATE TABLE IF NOT EXISTS local_users (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    email           TEXT NOT NULL,
+    password_hash   TEXT NOT NULL,
+    role            TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    disabled        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at   TIMESTAMPTZ
+);
+
+-- Users sign in by email before any tenant is known, so emails are unique
+-- across tenants and the table has no tenant isolation policy.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_local_users_email ON local_users(lower(email));
+CREATE INDEX IF NOT EXISTS idx_local_users_tenant ON local_users(tenant_id);
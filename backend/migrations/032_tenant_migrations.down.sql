@@ -0,0 +1,6 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 032_tenant_migrations (rollback)
+
+DROP TABLE IF EXISTS tenant_migration_chunks;
+DROP TABLE IF EXISTS tenant_migrations;
+DROP TABLE IF EXISTS tenant_clickhouse_routes;
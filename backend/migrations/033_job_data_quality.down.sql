@@ -0,0 +1,6 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 033_job_data_quality (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS ingestion_reconciliation,
+    DROP COLUMN IF EXISTS data_quality;
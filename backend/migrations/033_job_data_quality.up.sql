@@ -0,0 +1,11 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 033_job_data_quality
+-- Reconciliation of the entries stored for a job with the JAR's counts
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS data_quality TEXT
+        CHECK (data_quality IN ('ok', 'minor_divergence', 'major_divergence')),
+    ADD COLUMN IF NOT EXISTS ingestion_reconciliation JSONB;
+
+COMMENT ON COLUMN analysis_jobs.data_quality IS 'How far the stored entries diverge from the JAR counts: ok, minor_divergence or major_divergence';
+COMMENT ON COLUMN analysis_jobs.ingestion_reconciliation IS 'Per log type JAR, dropped, expected and stored entry counts and their divergence';
@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 034_job_focus_window (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS focus_window;
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 034_job_focus_window
+-- The stretch of a capture the dashboard opens on
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS focus_window JSONB;
+
+COMMENT ON COLUMN analysis_jobs.focus_window IS 'Contiguous time window deviating most from the capture baseline in error rate, p95 duration and queue time; NULL when none stands out';
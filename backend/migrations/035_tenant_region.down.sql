@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 035_tenant_region (rollback)
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS region;
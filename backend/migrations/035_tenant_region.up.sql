@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 035_tenant_region
+-- The data residency region a tenant's files and log entries are kept in
+
+ALTER TABLE tenants
+    ADD COLUMN IF NOT EXISTS region TEXT NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN tenants.region IS 'Region of STORAGE_REGIONS holding the tenant''s objects and ClickHouse data; empty for the default storage';
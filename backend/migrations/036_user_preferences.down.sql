@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 036_user_preferences (rollback)
+
+DROP TABLE IF EXISTS user_preferences;
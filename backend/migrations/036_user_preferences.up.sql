@@ -0,0 +1,31 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 036_user_preferences
+-- Per-user preference documents (page sizes, column layouts, dashboard
+-- arrangements), one per registered namespace, and the tenant defaults they
+-- are merged over.
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL,
+    namespace       TEXT NOT NULL,
+    schema_version  INTEGER NOT NULL CHECK (schema_version > 0),
+    document        JSONB NOT NULL,
+    revision        INTEGER NOT NULL CHECK (revision > 0),
+    updated_by      TEXT NOT NULL DEFAULT '',
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, user_id, namespace)
+);
+
+COMMENT ON COLUMN user_preferences.user_id IS 'Empty for the tenant defaults of the namespace';
+COMMENT ON COLUMN user_preferences.revision IS 'Incremented on every write; the ETag writers must match';
+
+ALTER TABLE user_preferences ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'user_preferences') THEN
+        CREATE POLICY tenant_isolation ON user_preferences
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
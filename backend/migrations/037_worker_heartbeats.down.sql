@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 037_worker_heartbeats (rollback)
+
+DROP INDEX IF EXISTS idx_analysis_jobs_completed_at;
+DROP TABLE IF EXISTS worker_heartbeats;
@@ -0,0 +1,18 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 037_worker_heartbeats
+-- Worker liveness for the operators' queue status. Workers are shared by
+-- all tenants, so the table has no tenant isolation policy.
+
+CREATE TABLE IF NOT EXISTS worker_heartbeats (
+    worker_id     TEXT PRIMARY KEY,
+    hostname      TEXT NOT NULL DEFAULT '',
+    started_at    TIMESTAMPTZ NOT NULL,
+    running_jobs  INTEGER NOT NULL DEFAULT 0,
+    max_jobs      INTEGER NOT NULL DEFAULT 0,
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The queue status counts the jobs finished recently for its throughput;
+-- active jobs are found through idx_analysis_jobs_queue.
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_completed_at ON analysis_jobs(completed_at)
+    WHERE completed_at IS NOT NULL;
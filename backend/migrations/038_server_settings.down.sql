@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 038_server_settings (rollback)
+
+DROP TABLE IF EXISTS server_settings;
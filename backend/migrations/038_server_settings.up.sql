@@ -0,0 +1,14 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 038_server_settings
+-- Dynamic server settings set by admins at runtime. Every change adds a new
+-- version, which keeps who changed what. The settings apply to every
+-- tenant, so the table has no tenant isolation policy.
+
+CREATE TABLE IF NOT EXISTS server_settings (
+    version     INTEGER PRIMARY KEY CHECK (version > 0),
+    settings    JSONB NOT NULL,
+    created_by  TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN server_settings.settings IS 'Dynamic values by setting key; settings missing here take their environment or built-in value';
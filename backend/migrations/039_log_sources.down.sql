@@ -0,0 +1,7 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 039_log_sources (rollback)
+
+DROP INDEX IF EXISTS idx_analysis_jobs_incident_group;
+ALTER TABLE analysis_jobs DROP COLUMN IF EXISTS incident_group_id;
+DROP TABLE IF EXISTS incident_groups;
+ALTER TABLE log_files DROP COLUMN IF EXISTS source_type;
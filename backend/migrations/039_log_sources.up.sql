@@ -0,0 +1,36 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 039_log_sources
+-- The kind of log an uploaded file holds, and incident groups: lightweight
+-- groupings of the analyses of one incident, such as the AR Server log with
+-- the server's Tomcat access and JVM GC logs, whose timelines are overlaid.
+
+ALTER TABLE log_files
+    ADD COLUMN IF NOT EXISTS source_type TEXT NOT NULL DEFAULT 'ar_server'
+        CHECK (source_type IN ('ar_server', 'tomcat_access', 'jvm_gc'));
+
+CREATE TABLE IF NOT EXISTS incident_groups (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    created_by  TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_incident_groups_tenant ON incident_groups(tenant_id, created_at DESC);
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS incident_group_id UUID REFERENCES incident_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_incident_group ON analysis_jobs(incident_group_id)
+    WHERE incident_group_id IS NOT NULL;
+
+ALTER TABLE incident_groups ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'incident_groups') THEN
+        CREATE POLICY tenant_isolation ON incident_groups
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 040_idempotency_keys (rollback)
+
+DROP TABLE IF EXISTS idempotency_keys;
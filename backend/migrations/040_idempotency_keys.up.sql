@@ -0,0 +1,36 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 040_idempotency_keys
+-- Idempotency keys of mutating requests. The first request with a key
+-- claims it; its response is stored so that retries with the same key are
+-- answered with it instead of running again. Rows are deleted by the
+-- worker once expired.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    tenant_id        UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    endpoint         TEXT NOT NULL,
+    idempotency_key  TEXT NOT NULL,
+    request_hash     TEXT NOT NULL,
+    status_code      INTEGER,
+    content_type     TEXT NOT NULL DEFAULT '',
+    response_body    BYTEA,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until     TIMESTAMPTZ NOT NULL,
+    expires_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, endpoint, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+COMMENT ON COLUMN idempotency_keys.status_code IS 'Status of the stored response; NULL while the first request is in flight';
+COMMENT ON COLUMN idempotency_keys.locked_until IS 'When the claim of an in-flight request lapses, so that a replica dying mid-request does not block the key until it expires';
+
+ALTER TABLE idempotency_keys ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'idempotency_keys') THEN
+        CREATE POLICY tenant_isolation ON idempotency_keys
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 041_background_tasks (rollback)
+
+DROP TABLE IF EXISTS background_task_runs;
+DROP TABLE IF EXISTS background_tasks;
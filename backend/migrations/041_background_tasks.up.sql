@@ -0,0 +1,32 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 041_background_tasks
+-- Periodic tasks of the workers and their run history. A worker runs a due
+-- task only after taking its lock, so that replicas do not run it twice.
+-- Tasks are shared by all tenants, so the tables have no tenant isolation
+-- policy.
+
+CREATE TABLE IF NOT EXISTS background_tasks (
+    name              TEXT PRIMARY KEY,
+    schedule          TEXT NOT NULL,
+    timeout_seconds   INTEGER NOT NULL,
+    next_run_at       TIMESTAMPTZ NOT NULL,
+    registered_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    run_requested_at  TIMESTAMPTZ,
+    run_requested_by  TEXT NOT NULL DEFAULT '',
+    locked_by         TEXT NOT NULL DEFAULT '',
+    locked_until      TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS background_task_runs (
+    id           BIGSERIAL PRIMARY KEY,
+    task_name    TEXT NOT NULL REFERENCES background_tasks(name) ON DELETE CASCADE,
+    trigger      TEXT NOT NULL,
+    worker_id    TEXT NOT NULL,
+    started_at   TIMESTAMPTZ NOT NULL,
+    finished_at  TIMESTAMPTZ NOT NULL,
+    duration_ms  BIGINT NOT NULL,
+    outcome      TEXT NOT NULL,
+    error        TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_background_task_runs_task ON background_task_runs(task_name, started_at DESC);
@@ -0,0 +1,12 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 042_sandbox_tenants (rollback)
+
+DROP INDEX IF EXISTS idx_tenants_sandbox_expiry;
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS sandbox;
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS archived_at,
+    DROP COLUMN IF EXISTS sandbox_expires_at,
+    DROP COLUMN IF EXISTS sandbox;
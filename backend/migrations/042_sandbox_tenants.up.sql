@@ -0,0 +1,22 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 042_sandbox_tenants
+-- Sandbox tenants for trials and demos: short-lived, held to built-in
+-- quotas and left out of usage aggregates. Once expired, their data is
+-- purged and the tenant archived.
+
+ALTER TABLE tenants
+    ADD COLUMN IF NOT EXISTS sandbox BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS sandbox_expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS sandbox BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_tenants_sandbox_expiry
+    ON tenants (sandbox_expires_at)
+    WHERE sandbox AND archived_at IS NULL;
+
+COMMENT ON COLUMN tenants.sandbox IS 'Trial or demo tenant held to the built-in sandbox quotas until converted';
+COMMENT ON COLUMN tenants.sandbox_expires_at IS 'When the sandbox''s data is purged and the tenant archived';
+COMMENT ON COLUMN tenants.archived_at IS 'When the tenant was archived; archived tenants have no data left';
+COMMENT ON COLUMN analysis_jobs.sandbox IS 'Whether the job belongs to a sandbox tenant, cleared when the tenant is converted';
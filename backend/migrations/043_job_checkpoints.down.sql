@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 043_job_checkpoints (rollback)
+
+DROP TABLE IF EXISTS job_checkpoints;
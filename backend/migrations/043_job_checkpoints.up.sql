@@ -0,0 +1,36 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 043_job_checkpoints
+-- Progress of the ingestion of a job, saved as its stages complete, so that
+-- a job redelivered after a worker crash resumes where the crashed run
+-- stopped instead of running the JAR and inserting every entry again. The
+-- row is deleted when the job completes.
+
+CREATE TABLE IF NOT EXISTS job_checkpoints (
+    job_id           UUID PRIMARY KEY REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    tenant_id        UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    run_id           UUID NOT NULL,
+    stage            TEXT NOT NULL DEFAULT '',
+    jar_output_key   TEXT NOT NULL DEFAULT '',
+    output_format    TEXT NOT NULL DEFAULT '',
+    next_batch       INTEGER NOT NULL DEFAULT 0,
+    last_batch_token TEXT NOT NULL DEFAULT '',
+    cache_sections   TEXT[] NOT NULL DEFAULT '{}',
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE job_checkpoints ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'job_checkpoints') THEN
+        CREATE POLICY tenant_isolation ON job_checkpoints
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
+
+COMMENT ON COLUMN job_checkpoints.run_id IS 'Run the insert deduplication tokens are derived from; a full reprocess starts a new one';
+COMMENT ON COLUMN job_checkpoints.stage IS 'Last stage completed: jar, parse or ingest';
+COMMENT ON COLUMN job_checkpoints.jar_output_key IS 'Object storage key of the JAR report, reused instead of running the JAR again';
+COMMENT ON COLUMN job_checkpoints.next_batch IS 'Index of the first batch of entries not known to be inserted';
+COMMENT ON COLUMN job_checkpoints.cache_sections IS 'Analysis sections written to the cache by the last run';
@@ -0,0 +1,8 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 044_job_cost_models (rollback)
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS estimate_accuracy,
+    DROP COLUMN IF EXISTS estimate;
+
+DROP TABLE IF EXISTS job_cost_models;
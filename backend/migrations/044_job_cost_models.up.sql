@@ -0,0 +1,42 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 044_job_cost_models
+-- Per-tenant cost models fitted on completed jobs, which estimate how long
+-- analysing a file takes and what it stores before it is submitted. The
+-- estimate made at submission is kept with the job and compared with the
+-- job's actual cost once it completed.
+
+CREATE TABLE IF NOT EXISTS job_cost_models (
+    tenant_id             UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    source_type           TEXT NOT NULL,
+    jobs                  INTEGER NOT NULL,
+    rows_fit              JSONB NOT NULL,
+    jar_ms_fit            JSONB NOT NULL,
+    insert_ms_fit         JSONB NOT NULL,
+    storage_bytes_per_row DOUBLE PRECISION NOT NULL,
+    error_pct             DOUBLE PRECISION,
+    fitted_at             TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, source_type)
+);
+
+COMMENT ON COLUMN job_cost_models.rows_fit IS 'Rows stored over file bytes, as {intercept, slope}';
+COMMENT ON COLUMN job_cost_models.jar_ms_fit IS 'JAR wall time in ms over file bytes';
+COMMENT ON COLUMN job_cost_models.insert_ms_fit IS 'Time beyond the JAR run in ms over rows stored';
+COMMENT ON COLUMN job_cost_models.error_pct IS 'Mean absolute error of the processing time fitted, in percent';
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS estimate JSONB,
+    ADD COLUMN IF NOT EXISTS estimate_accuracy JSONB;
+
+COMMENT ON COLUMN analysis_jobs.estimate IS 'Cost estimated when the job was submitted';
+COMMENT ON COLUMN analysis_jobs.estimate_accuracy IS 'The estimate compared with the job''s actual cost, once completed';
+
+ALTER TABLE job_cost_models ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'job_cost_models') THEN
+        CREATE POLICY tenant_isolation ON job_cost_models
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
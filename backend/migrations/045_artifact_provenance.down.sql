@@ -0,0 +1,6 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 045_artifact_provenance (rollback)
+
+ALTER TABLE search_exports
+    DROP COLUMN IF EXISTS regenerated_from,
+    DROP COLUMN IF EXISTS provenance;
@@ -0,0 +1,12 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 045_artifact_provenance
+-- The provenance of each export: the query, analyses and data version it
+-- was generated from, who asked for it and the hash of the artifact, so
+-- that an attached file can be traced back and generated again.
+
+ALTER TABLE search_exports
+    ADD COLUMN IF NOT EXISTS provenance JSONB,
+    ADD COLUMN IF NOT EXISTS regenerated_from UUID REFERENCES search_exports(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN search_exports.provenance IS 'What the artifact was generated from, with its SHA-256, once complete';
+COMMENT ON COLUMN search_exports.regenerated_from IS 'The export whose provenance this one replays';
@@ -0,0 +1,9 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 046_capture_drift (rollback)
+
+DROP INDEX IF EXISTS idx_analysis_jobs_capture_baseline;
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS capture_baseline,
+    DROP COLUMN IF EXISTS capture_drift,
+    DROP COLUMN IF EXISTS capture_profile;
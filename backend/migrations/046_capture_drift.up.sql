@@ -0,0 +1,18 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 046_capture_drift
+-- How each capture was taken (JAR flags, log types present, capture window
+-- and file count) and how it differs from the tenant's reference capture:
+-- its designated baseline analysis, else its previous completed analysis.
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS capture_profile JSONB,
+    ADD COLUMN IF NOT EXISTS capture_drift JSONB,
+    ADD COLUMN IF NOT EXISTS capture_baseline BOOLEAN NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_analysis_jobs_capture_baseline
+    ON analysis_jobs(tenant_id)
+    WHERE capture_baseline;
+
+COMMENT ON COLUMN analysis_jobs.capture_profile IS 'How the capture was taken, recorded when the job completes';
+COMMENT ON COLUMN analysis_jobs.capture_drift IS 'Differences of the capture from the reference capture it was checked against';
+COMMENT ON COLUMN analysis_jobs.capture_baseline IS 'Set on the one analysis of the tenant later captures are checked against';
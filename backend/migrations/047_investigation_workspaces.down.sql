@@ -0,0 +1,4 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 047_investigation_workspaces (rollback)
+
+DROP TABLE IF EXISTS investigation_workspaces;
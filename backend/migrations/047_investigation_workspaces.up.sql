@@ -0,0 +1,36 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 047_investigation_workspaces
+-- Named investigation workspaces of an analysis: the pinned entries, saved
+-- queries, time window, forms, queues and notes an analyst built up, kept
+-- as one state document and optionally shared with the tenant.
+
+CREATE TABLE IF NOT EXISTS investigation_workspaces (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id          UUID NOT NULL REFERENCES analysis_jobs(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    owner_id        TEXT NOT NULL,
+    sharing         TEXT NOT NULL DEFAULT 'private' CHECK (sharing IN ('private', 'read_only', 'collaborative')),
+    schema_version  INTEGER NOT NULL CHECK (schema_version > 0),
+    state           JSONB NOT NULL,
+    version         INTEGER NOT NULL CHECK (version > 0),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_by      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_investigation_workspaces_job ON investigation_workspaces(tenant_id, job_id, updated_at DESC);
+
+COMMENT ON COLUMN investigation_workspaces.schema_version IS 'Layout version of the state document; older documents are upgraded when read';
+COMMENT ON COLUMN investigation_workspaces.version IS 'Incremented on every update; the version writers must match';
+
+ALTER TABLE investigation_workspaces ENABLE ROW LEVEL SECURITY;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policies WHERE policyname = 'tenant_isolation' AND tablename = 'investigation_workspaces') THEN
+        CREATE POLICY tenant_isolation ON investigation_workspaces
+            USING (tenant_id::TEXT = current_setting('app.tenant_id', true))
+            WITH CHECK (tenant_id::TEXT = current_setting('app.tenant_id', true));
+    END IF;
+END $$;
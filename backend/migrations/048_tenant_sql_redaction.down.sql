@@ -0,0 +1,5 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 048_tenant_sql_redaction (rollback)
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS sql_redaction;
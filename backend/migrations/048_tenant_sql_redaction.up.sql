@@ -0,0 +1,10 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 048_tenant_sql_redaction
+-- What becomes of the literal values of the SQL statements a tenant's
+-- captures log before they are stored
+
+ALTER TABLE tenants
+    ADD COLUMN IF NOT EXISTS sql_redaction TEXT NOT NULL DEFAULT 'off'
+        CHECK (sql_redaction IN ('off', 'hash', 'remove'));
+
+COMMENT ON COLUMN tenants.sql_redaction IS 'SQL literal redaction of analyses ingested from now on: off, hash (a token equal for equal values) or remove (a placeholder)';
@@ -0,0 +1,10 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 049_job_cancellation (rollback)
+
+UPDATE analysis_jobs SET status = 'failed', error_message = 'analysis cancelled' WHERE status = 'cancelled';
+ALTER TABLE analysis_jobs DROP CONSTRAINT IF EXISTS analysis_jobs_status_check;
+ALTER TABLE analysis_jobs ADD CONSTRAINT analysis_jobs_status_check
+    CHECK (status IN ('queued', 'parsing', 'analyzing', 'storing', 'complete', 'failed'));
+
+ALTER TABLE analysis_jobs
+    DROP COLUMN IF EXISTS cancel_requested_at;
@@ -0,0 +1,13 @@
+-- RemedyIQ PostgreSQL Schema
+-- Version: 049_job_cancellation
+-- Lets a user cancel an analysis. A queued job is cancelled at once; a
+-- running one is stopped by its worker, which polls for the request.
+
+ALTER TABLE analysis_jobs
+    ADD COLUMN IF NOT EXISTS cancel_requested_at TIMESTAMPTZ;
+
+ALTER TABLE analysis_jobs DROP CONSTRAINT IF EXISTS analysis_jobs_status_check;
+ALTER TABLE analysis_jobs ADD CONSTRAINT analysis_jobs_status_check
+    CHECK (status IN ('queued', 'parsing', 'analyzing', 'storing', 'complete', 'failed', 'cancelled'));
+
+COMMENT ON COLUMN analysis_jobs.cancel_requested_at IS 'When a user asked for the job to be cancelled; once set the job can only end cancelled';
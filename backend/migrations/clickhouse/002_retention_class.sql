@@ -0,0 +1,9 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 002_retention_class
+-- Per-tenant retention. The worker stamps retention_class on every row from
+-- the tenant's plan; the TTL built from it (move to the cold volume, then
+-- delete) is applied by the worker's retention reconciler, which replaces the
+-- table-wide 90 day TTL from 001_init.
+
+ALTER TABLE remedyiq.log_entries
+    ADD COLUMN IF NOT EXISTS retention_class LowCardinality(String) DEFAULT 'standard' AFTER error_message;
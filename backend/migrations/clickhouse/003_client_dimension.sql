@@ -0,0 +1,10 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 003_client_dimension
+-- Client program and address of API calls. The worker reads them from the
+-- "from <client> (protocol N) at IP address <ip>" suffix of API log lines,
+-- carries them to the rest of the RPC, and falls back to the JAR's client
+-- aggregates for lines without the suffix. Rows ingested earlier keep ''.
+
+ALTER TABLE remedyiq.log_entries
+    ADD COLUMN IF NOT EXISTS client LowCardinality(String) DEFAULT '' AFTER form,
+    ADD COLUMN IF NOT EXISTS client_ip String DEFAULT '' AFTER client;
@@ -0,0 +1,7 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 004_noise_flag
+-- Entries an ingestion filter rule flags rather than drops. Searches leave
+-- them out unless include_noise=true; rows ingested earlier are not noise.
+
+ALTER TABLE remedyiq.log_entries
+    ADD COLUMN IF NOT EXISTS is_noise Bool DEFAULT false AFTER retention_class;
@@ -0,0 +1,8 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 005_sample_weight
+-- The number of entries each stored entry stands for. Entries of a sampled
+-- capture carry the sample rate; count analytics sum the weight rather than
+-- count rows. Rows ingested earlier stand for themselves.
+
+ALTER TABLE remedyiq.log_entries
+    ADD COLUMN IF NOT EXISTS sample_weight UInt32 DEFAULT 1 AFTER is_noise;
@@ -0,0 +1,40 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 006_minute_rollup
+-- The entries of every job summed per minute, log type and queue. The time
+-- series, histogram, focus metrics, queue load and error onset read it
+-- instead of log_entries whenever they bucket by a minute or more. The
+-- worker builds a job's rows once its entries are stored, and again when the
+-- job is processed anew or copied to another cluster, rather than through a
+-- materialized view: sample weight resets are mutations a view never sees.
+-- Rows are deleted with the job's entries. Jobs ingested earlier have no rows
+-- and are read from log_entries. The TTL follows the longest retention
+-- class, so the sums of a job may outlive entries of a shorter class.
+-- Purging the job deletes both.
+--
+-- entries, errors and the *_weighted sums are weighted by sample_weight.
+-- busy_ms and threaded_entries count the API calls run on a known thread,
+-- unweighted, as the queue load does.
+
+CREATE TABLE IF NOT EXISTS remedyiq.log_rollup_minute (
+    tenant_id               String,
+    job_id                  String,
+    minute                  DateTime,
+    log_type                Enum8('API' = 1, 'SQL' = 2, 'FLTR' = 3, 'ESCL' = 4),
+    queue                   String,
+    entries                 UInt64,
+    errors                  UInt64,
+    duration_ms_weighted    UInt64,
+    queue_time_ms_weighted  UInt64,
+    max_sample_weight       UInt32,
+    threaded_entries        UInt64,
+    busy_ms                 UInt64,
+    first_ts                DateTime64(3),
+    last_ts                 DateTime64(3),
+    p95_duration_ms         AggregateFunction(quantileExactWeighted(0.95), UInt32, UInt32),
+    threads                 AggregateFunction(uniqExact, String)
+)
+ENGINE = MergeTree()
+PARTITION BY (tenant_id, toYYYYMM(minute))
+ORDER BY (tenant_id, job_id, minute, log_type, queue)
+TTL minute + INTERVAL 365 DAY DELETE
+SETTINGS index_granularity = 8192;
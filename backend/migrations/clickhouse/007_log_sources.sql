@@ -0,0 +1,37 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 007_log_sources
+-- Log types of non-AR log sources: the requests of a Tomcat access log and
+-- the pauses of a JVM GC log. Adding values to the end of an Enum8 only
+-- changes metadata. The minute aggregates view is recreated rather than
+-- altered, since ClickHouse does not alter the inner table of a view; its
+-- states are only ever deleted with a job's entries, never read.
+
+ALTER TABLE remedyiq.log_entries
+    MODIFY COLUMN log_type Enum8('API' = 1, 'SQL' = 2, 'FLTR' = 3, 'ESCL' = 4, 'HTTP' = 5, 'GC' = 6);
+
+ALTER TABLE remedyiq.log_rollup_minute
+    MODIFY COLUMN log_type Enum8('API' = 1, 'SQL' = 2, 'FLTR' = 3, 'ESCL' = 4, 'HTTP' = 5, 'GC' = 6);
+
+DROP VIEW IF EXISTS remedyiq.log_entries_aggregates;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS remedyiq.log_entries_aggregates
+ENGINE = AggregatingMergeTree()
+PARTITION BY (tenant_id, toYYYYMM(period_start))
+ORDER BY (tenant_id, job_id, log_type, period_start)
+AS SELECT
+    tenant_id,
+    job_id,
+    log_type,
+    toStartOfMinute(timestamp) AS period_start,
+    countState() AS entry_count,
+    countIfState(success = true) AS success_count,
+    countIfState(success = false) AS failure_count,
+    avgState(duration_ms) AS avg_duration_ms,
+    maxState(duration_ms) AS max_duration_ms,
+    minState(duration_ms) AS min_duration_ms,
+    sumState(duration_ms) AS sum_duration_ms,
+    uniqExactState(user) AS unique_users,
+    uniqExactState(form) AS unique_forms,
+    uniqExactState(sql_table) AS unique_tables
+FROM remedyiq.log_entries
+GROUP BY tenant_id, job_id, log_type, period_start;
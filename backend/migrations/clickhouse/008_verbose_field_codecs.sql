@@ -0,0 +1,16 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 008_verbose_field_codecs
+-- raw_text and sql_statement hold most of the bytes of log_entries and are
+-- rarely read back in full. They are compressed with ZSTD rather than the
+-- default LZ4; parts written earlier are recompressed as they merge.
+-- raw_text_truncated marks entries whose raw text was cut short at
+-- ingestion; rows ingested earlier hold their full text.
+
+ALTER TABLE remedyiq.log_entries
+    MODIFY COLUMN raw_text String DEFAULT '' CODEC(ZSTD(3));
+
+ALTER TABLE remedyiq.log_entries
+    MODIFY COLUMN sql_statement String DEFAULT '' CODEC(ZSTD(3));
+
+ALTER TABLE remedyiq.log_entries
+    ADD COLUMN IF NOT EXISTS raw_text_truncated Bool DEFAULT false AFTER sample_weight;
@@ -0,0 +1,17 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 009_fulltext_token_index
+-- Token bloom filter indexes of raw_text and error_message, which let the
+-- plain words of a free-text search (hasToken) skip the granules without
+-- them instead of scanning the columns. They are built on the lowercased
+-- text, as searches match words case-insensitively. Parts written earlier
+-- are indexed by the MATERIALIZE mutations, which run in the background.
+
+ALTER TABLE remedyiq.log_entries
+    ADD INDEX IF NOT EXISTS idx_raw_text_tokens lower(raw_text) TYPE tokenbf_v1(32768, 3, 0) GRANULARITY 1;
+
+ALTER TABLE remedyiq.log_entries
+    ADD INDEX IF NOT EXISTS idx_error_message_tokens lower(error_message) TYPE tokenbf_v1(8192, 3, 0) GRANULARITY 1;
+
+ALTER TABLE remedyiq.log_entries MATERIALIZE INDEX idx_raw_text_tokens;
+
+ALTER TABLE remedyiq.log_entries MATERIALIZE INDEX idx_error_message_tokens;
@@ -0,0 +1,9 @@
+-- RemedyIQ ClickHouse Schema
+-- Version: 010_insert_deduplication
+-- Keeps the tokens of the latest inserted blocks of log_entries, so that a
+-- worker resuming a crashed job can send again the batch it is unsure was
+-- stored: a batch inserted with a token already seen is dropped. Without a
+-- window, non-replicated tables ignore insert deduplication tokens.
+
+ALTER TABLE remedyiq.log_entries
+    MODIFY SETTING non_replicated_deduplication_window = 1000;
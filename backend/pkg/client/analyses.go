@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CreateAnalysis submits an uploaded file for analysis. The request carries
+// an Idempotency-Key, so a retried submission creates one analysis.
+func (c *Client) CreateAnalysis(ctx context.Context, body CreateAnalysisRequest) (*AnalysisJob, error) {
+	req := &request{method: http.MethodPost, path: "/analysis", idempotent: true}
+	if err := req.setJSON(body); err != nil {
+		return nil, err
+	}
+	var job AnalysisJob
+	if err := c.doJSON(ctx, req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// EstimateAnalysis estimates the duration and cost of an analysis before it
+// is submitted.
+func (c *Client) EstimateAnalysis(ctx context.Context, body EstimateRequest) (*JobEstimate, error) {
+	var est JobEstimate
+	if err := c.sendJSON(ctx, http.MethodPost, "/analyses/estimate", body, &est); err != nil {
+		return nil, err
+	}
+	return &est, nil
+}
+
+// ListAnalysesOptions filter the analyses ListAnalyses returns.
+type ListAnalysesOptions struct {
+	InvestigationStatus InvestigationStatus
+	Assignee            string
+}
+
+// ListAnalyses lists the analyses of the tenant. opts may be nil.
+func (c *Client) ListAnalyses(ctx context.Context, opts *ListAnalysesOptions) ([]AnalysisJob, error) {
+	q := url.Values{}
+	if opts != nil {
+		setParam(q, "investigation_status", string(opts.InvestigationStatus))
+		setParam(q, "assignee", opts.Assignee)
+	}
+	return c.listJobs(ctx, &request{method: http.MethodGet, path: "/analysis", query: q})
+}
+
+// ListTrash lists the analyses of the tenant in the trash, most recently
+// deleted first.
+func (c *Client) ListTrash(ctx context.Context) ([]AnalysisJob, error) {
+	return c.listJobs(ctx, &request{method: http.MethodGet, path: "/analyses/trash"})
+}
+
+func (c *Client) listJobs(ctx context.Context, req *request) ([]AnalysisJob, error) {
+	var resp struct {
+		Jobs []AnalysisJob `json:"jobs"`
+	}
+	if err := c.doJSON(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// GetAnalysis returns an analysis.
+func (c *Client) GetAnalysis(ctx context.Context, jobID string) (*AnalysisJob, error) {
+	var job AnalysisJob
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s", jobID)}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// DeleteAnalysis moves an analysis to the trash, or, with force, which
+// needs administrator access, deletes it and its data for good.
+func (c *Client) DeleteAnalysis(ctx context.Context, jobID string, force bool) error {
+	q := url.Values{}
+	if force {
+		q.Set("force", "true")
+	}
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: pathf("/analysis/%s", jobID), query: q}, nil)
+}
+
+// RestoreAnalysis restores an analysis from the trash.
+func (c *Client) RestoreAnalysis(ctx context.Context, jobID string) (*AnalysisJob, error) {
+	req := &request{method: http.MethodPost, path: pathf("/analyses/%s/restore", jobID), idempotent: true}
+	var job AnalysisJob
+	if err := c.doJSON(ctx, req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// AnalysisEvents returns the timeline of an analysis.
+func (c *Client) AnalysisEvents(ctx context.Context, jobID string) (*AnalysisEvents, error) {
+	var events AnalysisEvents
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/events", jobID)}, &events); err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
+
+// WaitForAnalysis polls an analysis every interval until it completes or
+// fails, and returns it. A failed analysis is returned with an error.
+func (c *Client) WaitForAnalysis(ctx context.Context, jobID string, interval time.Duration) (*AnalysisJob, error) {
+	for {
+		job, err := c.GetAnalysis(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		switch job.Status {
+		case JobStatusComplete:
+			return job, nil
+		case JobStatusFailed:
+			msg := "no error message"
+			if job.ErrorMessage != nil {
+				msg = *job.ErrorMessage
+			}
+			return job, fmt.Errorf("client: analysis %s failed: %s", jobID, msg)
+		}
+		if err := c.sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// UpdateInvestigation updates the investigation of an analysis.
+func (c *Client) UpdateInvestigation(ctx context.Context, jobID string, update InvestigationUpdate) (*InvestigationResponse, error) {
+	var resp InvestigationResponse
+	if err := c.sendJSON(ctx, http.MethodPatch, pathf("/analysis/%s/investigation", jobID), update, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// InvestigationHistory returns the changes made to the investigation of an
+// analysis.
+func (c *Client) InvestigationHistory(ctx context.Context, jobID string) (*InvestigationHistory, error) {
+	var history InvestigationHistory
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/investigation/history", jobID)}, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// setParam sets a query parameter unless v is empty.
+func setParam(q url.Values, name, v string) {
+	if v != "" {
+		q.Set(name, v)
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyses(t *testing.T) {
+	const jobID = "00000000-0000-0000-0000-0000000000aa"
+	job := map[string]any{"id": jobID, "status": "complete"}
+
+	var method, path, query string
+	var body map[string]any
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, query, body = r.Method, r.URL.Path, r.URL.RawQuery, nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/analysis":
+			writeJSON(w, http.StatusAccepted, map[string]any{"id": jobID, "status": "queued"})
+		case r.URL.Path == "/api/v1/analysis", r.URL.Path == "/api/v1/analyses/trash":
+			writeJSON(w, http.StatusOK, map[string]any{"jobs": []any{job}, "pagination": Pagination{Page: 1}})
+		case r.URL.Path == "/api/v1/analyses/estimate":
+			writeJSON(w, http.StatusOK, map[string]any{"queue": "normal"})
+		case r.URL.Path == "/api/v1/analysis/"+jobID+"/events":
+			writeJSON(w, http.StatusOK, map[string]any{"job_id": jobID, "status": "parsing", "events": []any{}, "idle_ms": 1500})
+		case r.URL.Path == "/api/v1/analysis/"+jobID+"/investigation":
+			writeJSON(w, http.StatusOK, map[string]any{"job_id": jobID, "investigation": map[string]any{"status": "investigating", "version": 4}})
+		case r.URL.Path == "/api/v1/analysis/"+jobID+"/investigation/history":
+			writeJSON(w, http.StatusOK, map[string]any{"job_id": jobID, "events": []any{map[string]any{}}})
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, http.StatusOK, job)
+		}
+	}))
+	ctx := context.Background()
+
+	sync := false
+	created, err := c.CreateAnalysis(ctx, CreateAnalysisRequest{
+		FileID:   "f1",
+		Priority: "high",
+		Sampling: &Sampling{Rate: 10, SlowThresholdMS: 500},
+		Sync:     &sync,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, JobStatus("queued"), created.Status)
+	assert.Equal(t, map[string]any{
+		"file_id": "f1", "priority": "high", "sync": false,
+		"sampling": map[string]any{"rate": float64(10), "slow_threshold_ms": float64(500)},
+	}, body)
+
+	jobs, err := c.ListAnalyses(ctx, &ListAnalysesOptions{InvestigationStatus: "investigating", Assignee: "ana"})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, jobID, jobs[0].ID.String())
+	assert.Equal(t, "assignee=ana&investigation_status=investigating", query)
+
+	_, err = c.ListAnalyses(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, query)
+
+	trash, err := c.ListTrash(ctx)
+	require.NoError(t, err)
+	assert.Len(t, trash, 1)
+	assert.Equal(t, "/api/v1/analyses/trash", path)
+
+	est, err := c.EstimateAnalysis(ctx, EstimateRequest{SizeBytes: 1 << 20, SourceType: "ar_server"})
+	require.NoError(t, err)
+	assert.NotNil(t, est)
+	assert.Equal(t, map[string]any{"size_bytes": float64(1 << 20), "source_type": "ar_server"}, body)
+
+	got, err := c.GetAnalysis(ctx, jobID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusComplete, got.Status)
+
+	require.NoError(t, c.DeleteAnalysis(ctx, jobID, false))
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Empty(t, query)
+	require.NoError(t, c.DeleteAnalysis(ctx, jobID, true))
+	assert.Equal(t, "force=true", query)
+
+	restored, err := c.RestoreAnalysis(ctx, jobID)
+	require.NoError(t, err)
+	assert.Equal(t, jobID, restored.ID.String())
+	assert.Equal(t, "/api/v1/analyses/"+jobID+"/restore", path)
+
+	events, err := c.AnalysisEvents(ctx, jobID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusParsing, events.Status)
+	require.NotNil(t, events.IdleMS)
+	assert.Equal(t, int64(1500), *events.IdleMS)
+
+	version := 3
+	status := InvestigationStatus("investigating")
+	inv, err := c.UpdateInvestigation(ctx, jobID, InvestigationUpdate{Version: &version, Status: &status})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPatch, method)
+	assert.Equal(t, map[string]any{"version": float64(3), "status": "investigating", "notes": nil, "assignee": nil}, body)
+	assert.Equal(t, 4, inv.Investigation.Version)
+
+	history, err := c.InvestigationHistory(ctx, jobID)
+	require.NoError(t, err)
+	assert.Len(t, history.Events, 1)
+}
+
+func TestWaitForAnalysis(t *testing.T) {
+	statuses := []string{"queued", "parsing", "complete"}
+	calls := 0
+	c, waits := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"status": statuses[calls]})
+		calls++
+	}))
+
+	job, err := c.WaitForAnalysis(context.Background(), "j1", 250)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusComplete, job.Status)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, *waits, 2)
+}
+
+func TestWaitForAnalysis_Failed(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "failed", "error_message": "JAR exited 1"})
+	}))
+
+	job, err := c.WaitForAnalysis(context.Background(), "j1", 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JAR exited 1")
+	require.NotNil(t, job)
+	assert.Equal(t, JobStatusFailed, job.Status)
+}
@@ -0,0 +1,400 @@
+// Package client is a Go client of the RemedyIQ API. Its methods return the
+// server's own domain types, re-exported in types.go, so responses decode
+// into exactly what the server encodes.
+//
+//	c, err := client.New("https://remedyiq.example.com", client.WithBearerToken(token))
+//	file, err := c.UploadFile(ctx, "arapi.log", f, "")
+//	job, err := c.CreateAnalysis(ctx, client.CreateAnalysisRequest{FileID: file.ID.String()})
+//	job, err = c.WaitForAnalysis(ctx, job.ID.String(), time.Second)
+//	dashboard, err := c.Dashboard(ctx, job.ID.String(), false)
+//
+// Requests are retried with exponential backoff on 429 and 5xx responses,
+// honouring Retry-After, as long as retrying them is safe: reads, PUT and
+// DELETE, and the POSTs the server deduplicates by Idempotency-Key.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// apiPrefix is the path of the API under the base URL.
+	apiPrefix = "/api/v1"
+
+	defaultMaxRetries = 3
+	defaultMinBackoff = 250 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+
+	// idempotencyKeyHeader carries the key the server deduplicates
+	// retried POSTs by.
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// Client calls the RemedyIQ API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+
+	// auth sets the credentials of a request.
+	auth func(h http.Header)
+
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	// sleep waits d or until ctx is done; replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBearerToken authenticates requests with a session token of the
+// identity provider.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.auth = func(h http.Header) { h.Set("Authorization", "Bearer "+token) }
+	}
+}
+
+// WithDevIdentity authenticates requests as userID of tenantID through the
+// headers a server in development mode accepts instead of a token.
+func WithDevIdentity(userID, tenantID string) Option {
+	return func(c *Client) {
+		c.auth = func(h http.Header) {
+			h.Set("X-Dev-User-ID", userID)
+			h.Set("X-Dev-Tenant-ID", tenantID)
+		}
+	}
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetry retries a failed request up to maxRetries times, waiting the
+// response's Retry-After or else an exponential backoff from minBackoff,
+// with jitter, capped at maxBackoff. maxRetries 0 disables retries.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries, c.minBackoff, c.maxBackoff = maxRetries, minBackoff, maxBackoff
+	}
+}
+
+// WithUserAgent sets the User-Agent of requests.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New creates a client of the API served at baseURL, the root of the
+// server such as https://remedyiq.example.com.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q is not http or https", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		userAgent:  "remedyiq-go-client",
+		auth:       func(http.Header) {},
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		sleep:      sleepContext,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError is an error response of the API.
+type APIError struct {
+	StatusCode int
+	Code       string          `json:"code"`
+	Message    string          `json:"message"`
+	Details    json.RawMessage `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("remedyiq: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("remedyiq: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response of the API.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// request is one call of the API.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+
+	// body is the encoded body; it is sent again on retries. stream is a
+	// body that can be read once, for requests that are never retried.
+	body   []byte
+	stream io.Reader
+
+	// idempotent marks a POST the server deduplicates by Idempotency-Key,
+	// so that it may be retried.
+	idempotent bool
+}
+
+// pathf formats a path of the API, escaping each of args as a path
+// segment.
+func pathf(format string, args ...string) string {
+	escaped := make([]any, len(args))
+	for i, arg := range args {
+		escaped[i] = url.PathEscape(arg)
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// Do calls an endpoint the client has no method for: method on path, the
+// escaped path under /api/v1 with its query. body, when not nil, is sent as JSON;
+// out, when not nil, is decoded from the JSON response.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	path, rawQuery, _ := strings.Cut(path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Errorf("client: invalid query: %w", err)
+	}
+	req := &request{method: method, path: path, query: query}
+	if body != nil {
+		if err := req.setJSON(body); err != nil {
+			return err
+		}
+	}
+	return c.doJSON(ctx, req, out)
+}
+
+// setJSON encodes v as the body of the request.
+func (r *request) setJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("client: encode %s %s: %w", r.method, r.path, err)
+	}
+	r.body = b
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	r.header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// sendJSON sends body as JSON with method to path and decodes the JSON
+// response into out.
+func (c *Client) sendJSON(ctx context.Context, method, path string, body, out any) error {
+	req := &request{method: method, path: path}
+	if err := req.setJSON(body); err != nil {
+		return err
+	}
+	return c.doJSON(ctx, req, out)
+}
+
+// doJSON sends the request and decodes its JSON response into out, unless
+// out is nil.
+func (c *Client) doJSON(ctx context.Context, r *request, out any) error {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode %s %s: %w", r.method, r.path, err)
+	}
+	return nil
+}
+
+// doRaw sends the request and reads its whole response.
+func (c *Client) doRaw(ctx context.Context, r *request) ([]byte, http.Header, error) {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client: read %s %s: %w", r.method, r.path, err)
+	}
+	return body, resp.Header, nil
+}
+
+// do sends the request, retrying it while it may be, and returns the first
+// successful response; its body must be closed. Error responses are
+// returned as *APIError.
+func (c *Client) do(ctx context.Context, r *request) (*http.Response, error) {
+	if r.idempotent {
+		if r.header == nil {
+			r.header = http.Header{}
+		}
+		if r.header.Get(idempotencyKeyHeader) == "" {
+			r.header.Set(idempotencyKeyHeader, newIdempotencyKey())
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, r)
+		if err == nil && resp.StatusCode < 400 {
+			return resp, nil
+		}
+
+		var apiErr error
+		var retryAfter time.Duration
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			apiErr = fmt.Errorf("client: %s %s: %w", r.method, r.path, err)
+		} else {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			apiErr = decodeError(resp)
+		}
+		if attempt >= c.maxRetries || !c.retryable(r, resp, err) {
+			return nil, apiErr
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send makes one attempt of the request.
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	target := c.baseURL.String() + apiPrefix + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	var body io.Reader
+	switch {
+	case r.stream != nil:
+		body = r.stream
+	case r.body != nil:
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	c.auth(req.Header)
+	return c.httpClient.Do(req)
+}
+
+// retryable reports whether a failed attempt of r may be retried: a 429,
+// which the server rejected before handling, always; a 5xx or a transport
+// error only for requests that are safe to repeat. Streamed bodies cannot
+// be sent again.
+func (c *Client) retryable(r *request, resp *http.Response, err error) bool {
+	if r.stream != nil {
+		return false
+	}
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp != nil && resp.StatusCode < 500 {
+		return false
+	}
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.idempotent
+}
+
+// backoff is the wait before retry attempt+1: an exponential backoff from
+// minBackoff with full jitter, capped at maxBackoff.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.minBackoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + mathrand.N(d/2+1)
+}
+
+// decodeError reads an error response, closing its body.
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(body, apiErr) != nil {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP
+// date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+func newIdempotencyKey() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,305 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient serves the client's requests with h. The client records
+// the waits between retries instead of sleeping.
+func newTestClient(t *testing.T, h http.Handler, opts ...Option) (*Client, *[]time.Duration) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, append([]Option{WithDevIdentity("user-1", "00000000-0000-0000-0000-000000000001")}, opts...)...)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		waits = append(waits, d)
+		mu.Unlock()
+		return ctx.Err()
+	}
+	return c, &waits
+}
+
+// writeJSON writes v as a JSON response with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{"code": code, "message": message})
+}
+
+func TestNew_RejectsBaseURL(t *testing.T) {
+	for _, base := range []string{"", "remedyiq.example.com", "ftp://remedyiq.example.com", "http://[::1"} {
+		_, err := New(base)
+		assert.Error(t, err, base)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	var got http.Header
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		writeJSON(w, http.StatusOK, map[string]any{"files": []any{}})
+	})
+
+	c, _ := newTestClient(t, h, WithBearerToken("tok"), WithUserAgent("ops-bot/1.0"))
+	_, err := c.ListFiles(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer tok", got.Get("Authorization"))
+	assert.Equal(t, "ops-bot/1.0", got.Get("User-Agent"))
+	assert.Empty(t, got.Get("X-Dev-User-ID"), "the last auth option wins")
+
+	c, _ = newTestClient(t, h)
+	_, err = c.ListFiles(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got.Get("X-Dev-User-ID"))
+	assert.Equal(t, "00000000-0000-0000-0000-000000000001", got.Get("X-Dev-Tenant-ID"))
+	assert.Empty(t, got.Get("Authorization"))
+}
+
+func TestClient_BaseURLPath(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		writeJSON(w, http.StatusOK, map[string]any{})
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL + "/remedyiq/")
+	require.NoError(t, err)
+	_, err = c.GetAnalysis(context.Background(), "a/b")
+	require.NoError(t, err)
+	assert.Equal(t, "/remedyiq/api/v1/analysis/a%2Fb", path)
+}
+
+func TestClient_APIError(t *testing.T) {
+	c, waits := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":"NOT_FOUND","message":"analysis job not found","details":{"job_id":"j1"}}`))
+	}))
+
+	_, err := c.GetAnalysis(context.Background(), "j1")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "NOT_FOUND", apiErr.Code)
+	assert.Equal(t, "analysis job not found", apiErr.Message)
+	assert.JSONEq(t, `{"job_id":"j1"}`, string(apiErr.Details))
+	assert.Equal(t, "remedyiq: 404 NOT_FOUND: analysis job not found", err.Error())
+	assert.True(t, IsNotFound(err))
+	assert.Empty(t, *waits, "4xx errors are not retried")
+}
+
+func TestClient_APIErrorNotJSON(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}), WithRetry(0, 0, 0))
+
+	_, err := c.GetAnalysis(context.Background(), "j1")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "bad gateway", apiErr.Message)
+	assert.Equal(t, "remedyiq: 502 Bad Gateway", err.Error())
+}
+
+func TestClient_RetriesHonourRetryAfter(t *testing.T) {
+	calls := 0
+	c, waits := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "7")
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "slow down")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"id": "00000000-0000-0000-0000-0000000000aa", "status": "complete"})
+	}))
+
+	job, err := c.GetAnalysis(context.Background(), "j1")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusComplete, job.Status)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{7 * time.Second, 7 * time.Second}, *waits)
+}
+
+func TestClient_RetryBackoff(t *testing.T) {
+	calls := 0
+	c, waits := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "try later")
+	}), WithRetry(4, 100*time.Millisecond, 300*time.Millisecond))
+
+	_, err := c.GetAnalysis(context.Background(), "j1")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode, "the last error is returned")
+	assert.Equal(t, 5, calls)
+
+	// Exponential from 100ms, capped at 300ms, with jitter down to half.
+	caps := []time.Duration{100, 200, 300, 300}
+	require.Len(t, *waits, len(caps))
+	for i, d := range *waits {
+		capped := caps[i] * time.Millisecond
+		assert.GreaterOrEqual(t, d, capped/2, "wait %d", i)
+		assert.LessOrEqual(t, d, capped, "wait %d", i)
+	}
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		call   func(c *Client) error
+		calls  int
+	}{
+		{"GET retries 5xx", http.StatusInternalServerError, func(c *Client) error {
+			_, err := c.ListFiles(context.Background())
+			return err
+		}, 3},
+		{"DELETE retries 5xx", http.StatusBadGateway, func(c *Client) error {
+			return c.DeleteThresholdRule(context.Background(), "r1")
+		}, 3},
+		{"POST does not retry 5xx", http.StatusInternalServerError, func(c *Client) error {
+			_, err := c.CreateThresholdRule(context.Background(), ThresholdRuleRequest{Name: "slow"})
+			return err
+		}, 1},
+		{"idempotent POST retries 5xx", http.StatusInternalServerError, func(c *Client) error {
+			_, err := c.CreateAnalysis(context.Background(), CreateAnalysisRequest{FileID: "f1"})
+			return err
+		}, 3},
+		{"POST retries 429", http.StatusTooManyRequests, func(c *Client) error {
+			_, err := c.CreateThresholdRule(context.Background(), ThresholdRuleRequest{Name: "slow"})
+			return err
+		}, 3},
+		{"PATCH does not retry 5xx", http.StatusServiceUnavailable, func(c *Client) error {
+			_, err := c.UpdateInvestigation(context.Background(), "j1", InvestigationUpdate{})
+			return err
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				writeError(w, tt.status, "ERR", "failed")
+			}), WithRetry(2, time.Millisecond, time.Millisecond))
+			assert.Error(t, tt.call(c))
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
+
+func TestClient_IdempotencyKeyStableAcrossRetries(t *testing.T) {
+	var keys []string
+	var bodies []string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		b, _ := json.Marshal(body)
+		bodies = append(bodies, string(b))
+		if len(keys) == 1 {
+			writeError(w, http.StatusBadGateway, "BAD_GATEWAY", "upstream")
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]any{"status": "queued"})
+	}))
+
+	_, err := c.CreateAnalysis(context.Background(), CreateAnalysisRequest{FileID: "f1", Priority: "high"})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, bodies[0], bodies[1], "the body is sent again")
+	assert.JSONEq(t, `{"file_id":"f1","priority":"high"}`, bodies[1])
+
+	_, err = c.CreateAnalysis(context.Background(), CreateAnalysisRequest{FileID: "f1"})
+	require.NoError(t, err)
+	assert.NotEqual(t, keys[0], keys[2], "each call has its own key")
+}
+
+func TestClient_RetryTransportError(t *testing.T) {
+	// A server that hangs up on the first request.
+	calls := 0
+	c, waits := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close()
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"files": []any{}})
+	}))
+
+	_, err := c.ListFiles(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, *waits, 1)
+}
+
+func TestClient_ContextCancelledDuringBackoff(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "slow down")
+	}))
+	c.sleep = sleepContext
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ListFiles(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-3"))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30"))
+
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(t, d, 50*time.Second)
+	assert.LessOrEqual(t, d, time.Minute)
+	assert.Equal(t, time.Duration(0), parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)))
+}
+
+func TestClient_Do(t *testing.T) {
+	var method, path, query string
+	var body map[string]any
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, query = r.Method, r.URL.Path, r.URL.RawQuery
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, http.StatusOK, map[string]any{"skills": []string{"summarize"}})
+	}))
+
+	var out struct {
+		Skills []string `json:"skills"`
+	}
+	err := c.Do(context.Background(), http.MethodPost, "/ai/skills?verbose=true", map[string]any{"q": "x"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/api/v1/ai/skills", path)
+	assert.Equal(t, "verbose=true", query)
+	assert.Equal(t, map[string]any{"q": "x"}, body)
+	assert.Equal(t, []string{"summarize"}, out.Skills)
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jarParsedMarker marks a section the JAR wrote, which has another shape
+// than the one the server computes.
+var jarParsedMarker = []byte(`"source":"jar_parsed"`)
+
+// Section is a dashboard section of one of two shapes: Computed, the
+// section the server computes from the dashboard, or JAR, the section as
+// the JAR wrote it. Exactly one is set.
+type Section[T, J any] struct {
+	Computed *T
+	JAR      *J
+}
+
+// IsJAR reports whether the section is the one the JAR wrote.
+func (s *Section[T, J]) IsJAR() bool { return s.JAR != nil }
+
+// UnmarshalJSON decodes the section into the shape its source marks it as.
+func (s *Section[T, J]) UnmarshalJSON(data []byte) error {
+	*s = Section[T, J]{}
+	if bytes.Contains(data, jarParsedMarker) {
+		s.JAR = new(J)
+		return json.Unmarshal(data, s.JAR)
+	}
+	s.Computed = new(T)
+	return json.Unmarshal(data, s.Computed)
+}
+
+// The dual-shape dashboard sections.
+type (
+	AggregatesSection       = Section[AggregatesResponse, JARAggregatesResponse]
+	ExceptionsSection       = Section[ExceptionsResponse, JARExceptionsResponse]
+	GapsSection             = Section[GapsResponse, JARGapsResponse]
+	ThreadStatsSection      = Section[ThreadStatsResponse, JARThreadStatsResponse]
+	FilterComplexitySection = Section[FilterComplexityResponse, JARFilterComplexityResponse]
+)
+
+// Dashboard returns the dashboard of a completed analysis. withGroup adds
+// the overlays of the other analyses of its incident group.
+func (c *Client) Dashboard(ctx context.Context, jobID string, withGroup bool) (*DashboardData, error) {
+	var data DashboardData
+	if err := c.getSection(ctx, jobID, "dashboard", groupQuery(withGroup), &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Aggregates returns the aggregates of a completed analysis.
+func (c *Client) Aggregates(ctx context.Context, jobID string) (*AggregatesSection, error) {
+	var s AggregatesSection
+	if err := c.getSection(ctx, jobID, "dashboard/aggregates", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// AggregatesPage returns one page of the groups of each aggregate of a
+// completed analysis, with the number of groups matching page.Name.
+// Zero fields of page take the server's defaults.
+func (c *Client) AggregatesPage(ctx context.Context, jobID string, page AggregatePage) (*AggregatesResponse, error) {
+	q := url.Values{}
+	if page.Limit > 0 {
+		q.Set("limit", strconv.Itoa(page.Limit))
+	}
+	q.Set("offset", strconv.Itoa(page.Offset))
+	setParam(q, "sort_by", string(page.SortBy))
+	setParam(q, "sort_order", page.SortOrder)
+	setParam(q, "name", page.Name)
+	var resp AggregatesResponse
+	if err := c.getSection(ctx, jobID, "dashboard/aggregates", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Exceptions returns the exceptions of a completed analysis. withGroup
+// adds the overlays of the other analyses of its incident group.
+func (c *Client) Exceptions(ctx context.Context, jobID string, withGroup bool) (*ExceptionsSection, error) {
+	var s ExceptionsSection
+	if err := c.getSection(ctx, jobID, "dashboard/exceptions", groupQuery(withGroup), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Gaps returns the gaps of a completed analysis.
+func (c *Client) Gaps(ctx context.Context, jobID string) (*GapsSection, error) {
+	var s GapsSection
+	if err := c.getSection(ctx, jobID, "dashboard/gaps", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Threads returns the thread statistics of a completed analysis.
+func (c *Client) Threads(ctx context.Context, jobID string) (*ThreadStatsSection, error) {
+	var s ThreadStatsSection
+	if err := c.getSection(ctx, jobID, "dashboard/threads", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Filters returns the filter complexity of a completed analysis.
+func (c *Client) Filters(ctx context.Context, jobID string) (*FilterComplexitySection, error) {
+	var s FilterComplexitySection
+	if err := c.getSection(ctx, jobID, "dashboard/filters", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// QueuedCalls returns the queued API calls of a completed analysis.
+func (c *Client) QueuedCalls(ctx context.Context, jobID string) (*QueuedCallsResponse, error) {
+	var resp QueuedCallsResponse
+	if err := c.getSection(ctx, jobID, "dashboard/queued-calls", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// LoggingActivity returns the logging activity of a completed analysis.
+func (c *Client) LoggingActivity(ctx context.Context, jobID string) (*LoggingActivityResponse, error) {
+	var resp LoggingActivityResponse
+	if err := c.getSection(ctx, jobID, "dashboard/logging-activity", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// FileMetadata returns the metadata of the files of a completed analysis.
+func (c *Client) FileMetadata(ctx context.Context, jobID string) (*FileMetadataResponse, error) {
+	var resp FileMetadataResponse
+	if err := c.getSection(ctx, jobID, "dashboard/file-metadata", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DelayedEscalations returns the escalations of a completed analysis that
+// ran at least minDelayMS late, at most limit of them. Zero values take
+// the server's defaults.
+func (c *Client) DelayedEscalations(ctx context.Context, jobID string, minDelayMS int64, limit int) (*DelayedEscalationsResponse, error) {
+	q := url.Values{}
+	if minDelayMS > 0 {
+		q.Set("min_delay_ms", strconv.FormatInt(minDelayMS, 10))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var resp DelayedEscalationsResponse
+	if err := c.getSection(ctx, jobID, "dashboard/delayed-escalations", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ErrorHeatmap returns the errors of a completed analysis by code and time
+// bucket: "1m", "5m", or empty to let the server pick. limit caps the
+// codes; zero takes the server's default.
+func (c *Client) ErrorHeatmap(ctx context.Context, jobID, bucket string, limit int) (*ErrorHeatmapResponse, error) {
+	q := url.Values{}
+	setParam(q, "bucket", bucket)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var resp ErrorHeatmapResponse
+	if err := c.getSection(ctx, jobID, "dashboard/exceptions/heatmap", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ThreadTimelineOptions select the threads and time of a thread timeline.
+// Zero fields take the server's defaults.
+type ThreadTimelineOptions struct {
+	Queue string
+	GapMS int64
+	Limit int
+	From  *time.Time
+	To    *time.Time
+}
+
+// ThreadTimeline returns the activity of the busiest threads of a completed
+// analysis over time. opts may be nil.
+func (c *Client) ThreadTimeline(ctx context.Context, jobID string, opts *ThreadTimelineOptions) (*ThreadTimelineResponse, error) {
+	q := url.Values{}
+	if opts != nil {
+		setParam(q, "queue", opts.Queue)
+		if opts.GapMS > 0 {
+			q.Set("gap_ms", strconv.FormatInt(opts.GapMS, 10))
+		}
+		if opts.Limit > 0 {
+			q.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		setTime(q, "from", opts.From)
+		setTime(q, "to", opts.To)
+	}
+	var resp ThreadTimelineResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analyses/%s/threads/timeline", jobID), query: q}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ErrorOnset returns when errors began in a completed analysis.
+func (c *Client) ErrorOnset(ctx context.Context, jobID string) (*ErrorOnset, error) {
+	var onset ErrorOnset
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analyses/%s/error-onset", jobID)}, &onset); err != nil {
+		return nil, err
+	}
+	return &onset, nil
+}
+
+// SQLTable returns the SQL statements of a completed analysis against one
+// table.
+func (c *Client) SQLTable(ctx context.Context, jobID, table string) (*SQLTableDrilldown, error) {
+	var drilldown SQLTableDrilldown
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analyses/%s/sql/tables/%s", jobID, table)}, &drilldown); err != nil {
+		return nil, err
+	}
+	return &drilldown, nil
+}
+
+// Legend returns the abbreviations of the API calls in a completed
+// analysis.
+func (c *Client) Legend(ctx context.Context, jobID string) (*APILegendResponse, error) {
+	var legend APILegendResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/legend", jobID)}, &legend); err != nil {
+		return nil, err
+	}
+	return &legend, nil
+}
+
+// ReportOptions shape a JAR report. Zero fields take the server's
+// defaults.
+type ReportOptions struct {
+	// Sections are the sections of the report, all when empty.
+	Sections []string
+	// Top is the rows of each ranked table.
+	Top int
+}
+
+// Report renders the report of a completed analysis as format, "txt" or
+// "md". opts may be nil.
+func (c *Client) Report(ctx context.Context, jobID, format string, opts *ReportOptions) (string, error) {
+	if format != "txt" && format != "md" {
+		return "", fmt.Errorf("client: report format %q is not txt or md", format)
+	}
+	q := url.Values{}
+	if opts != nil {
+		setParam(q, "sections", strings.Join(opts.Sections, ","))
+		if opts.Top > 0 {
+			q.Set("top", strconv.Itoa(opts.Top))
+		}
+	}
+	req := &request{
+		method: http.MethodGet,
+		path:   pathf("/analyses/%s/report.", jobID) + format,
+		query:  q,
+		header: http.Header{"Accept": {"text/plain, text/markdown"}},
+	}
+	body, _, err := c.doRaw(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// SectionTable returns a dashboard section as a table, format "csv" or
+// "xlsx", such as SectionTable(ctx, jobID, "dashboard/aggregates", "csv").
+func (c *Client) SectionTable(ctx context.Context, jobID, section, format string) ([]byte, error) {
+	req := &request{
+		method: http.MethodGet,
+		path:   pathf("/analysis/%s/", jobID) + section,
+		query:  url.Values{"format": {format}},
+	}
+	body, _, err := c.doRaw(ctx, req)
+	return body, err
+}
+
+// getSection reads a section of a completed analysis, a path under
+// /analysis/{job_id}.
+func (c *Client) getSection(ctx context.Context, jobID, section string, q url.Values, out any) error {
+	return c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/", jobID) + section, query: q}, out)
+}
+
+func groupQuery(withGroup bool) url.Values {
+	if !withGroup {
+		return nil
+	}
+	return url.Values{"include_group": {"true"}}
+}
+
+// setTime sets a time query parameter unless t is nil.
+func setTime(q url.Values, name string, t *time.Time) {
+	if t != nil {
+		q.Set(name, t.UTC().Format(time.RFC3339Nano))
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSections_DecodeEitherShape(t *testing.T) {
+	jar := false
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if jar {
+			_, _ = w.Write([]byte(`{"source":"jar_parsed","by_form":{"grand_total":null,"groups":[]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"api":{"groups":[{"name":"GE","count":3}]}}`))
+	}))
+	ctx := context.Background()
+
+	computed, err := c.Aggregates(ctx, "j1")
+	require.NoError(t, err)
+	assert.False(t, computed.IsJAR())
+	require.NotNil(t, computed.Computed)
+	require.NotNil(t, computed.Computed.API)
+	assert.Equal(t, "GE", computed.Computed.API.Groups[0].Name)
+
+	jar = true
+	parsed, err := c.Aggregates(ctx, "j1")
+	require.NoError(t, err)
+	assert.True(t, parsed.IsJAR())
+	assert.Nil(t, parsed.Computed)
+	assert.Equal(t, "jar_parsed", parsed.JAR.Source)
+}
+
+func TestSections_Paths(t *testing.T) {
+	var path, query string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		writeJSON(w, http.StatusOK, map[string]any{})
+	}))
+	ctx := context.Background()
+	const base = "/api/v1/analysis/j1/"
+
+	tests := []struct {
+		call  func() error
+		path  string
+		query string
+	}{
+		{func() error { _, err := c.Dashboard(ctx, "j1", false); return err }, base + "dashboard", ""},
+		{func() error { _, err := c.Dashboard(ctx, "j1", true); return err }, base + "dashboard", "include_group=true"},
+		{func() error { _, err := c.Exceptions(ctx, "j1", true); return err }, base + "dashboard/exceptions", "include_group=true"},
+		{func() error { _, err := c.Gaps(ctx, "j1"); return err }, base + "dashboard/gaps", ""},
+		{func() error { _, err := c.Threads(ctx, "j1"); return err }, base + "dashboard/threads", ""},
+		{func() error { _, err := c.Filters(ctx, "j1"); return err }, base + "dashboard/filters", ""},
+		{func() error { _, err := c.QueuedCalls(ctx, "j1"); return err }, base + "dashboard/queued-calls", ""},
+		{func() error { _, err := c.LoggingActivity(ctx, "j1"); return err }, base + "dashboard/logging-activity", ""},
+		{func() error { _, err := c.FileMetadata(ctx, "j1"); return err }, base + "dashboard/file-metadata", ""},
+		{func() error { _, err := c.DelayedEscalations(ctx, "j1", 5000, 20); return err }, base + "dashboard/delayed-escalations", "limit=20&min_delay_ms=5000"},
+		{func() error { _, err := c.ErrorHeatmap(ctx, "j1", "5m", 0); return err }, base + "dashboard/exceptions/heatmap", "bucket=5m"},
+		{func() error { _, err := c.Legend(ctx, "j1"); return err }, base + "legend", ""},
+		{func() error { _, err := c.ErrorOnset(ctx, "j1"); return err }, "/api/v1/analyses/j1/error-onset", ""},
+		{func() error { _, err := c.SQLTable(ctx, "j1", "T123"); return err }, "/api/v1/analyses/j1/sql/tables/T123", ""},
+		{func() error {
+			_, err := c.ThreadTimeline(ctx, "j1", &ThreadTimelineOptions{Queue: "Fast", GapMS: 250, Limit: 5})
+			return err
+		}, "/api/v1/analyses/j1/threads/timeline", "gap_ms=250&limit=5&queue=Fast"},
+		{func() error {
+			_, err := c.AggregatesPage(ctx, "j1", AggregatePage{Limit: 10, Offset: 20, SortBy: "avg_ms", SortOrder: "asc", Name: "HPD"})
+			return err
+		}, base + "dashboard/aggregates", "limit=10&name=HPD&offset=20&sort_by=avg_ms&sort_order=asc"},
+		{func() error { _, err := c.AggregatesPage(ctx, "j1", AggregatePage{}); return err }, base + "dashboard/aggregates", "offset=0"},
+	}
+	for _, tt := range tests {
+		require.NoError(t, tt.call())
+		assert.Equal(t, tt.path, path)
+		assert.Equal(t, tt.query, query, tt.path)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var path, query string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = w.Write([]byte("# Report\n"))
+	}))
+
+	report, err := c.Report(context.Background(), "j1", "md", &ReportOptions{Sections: []string{"api", "sql"}, Top: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "# Report\n", report)
+	assert.Equal(t, "/api/v1/analyses/j1/report.md", path)
+	assert.Equal(t, "sections=api%2Csql&top=5", query)
+
+	_, err = c.Report(context.Background(), "j1", "pdf", nil)
+	assert.Error(t, err)
+}
+
+func TestSectionTable(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/analysis/j1/dashboard/gaps", r.URL.Path)
+		assert.Equal(t, "csv", r.URL.Query().Get("format"))
+		w.Header().Set("Content-Type", "text/csv")
+		_, _ = w.Write([]byte("a,b\n1,2\n"))
+	}))
+
+	table, err := c.SectionTable(context.Background(), "j1", "dashboard/gaps", "csv")
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(table))
+}
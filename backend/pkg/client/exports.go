@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// EntryExport is the entries of a search exported synchronously.
+type EntryExport struct {
+	// Data is the file, CSV or JSON.
+	Data []byte
+	// TotalCount is the entries matching the search, of which
+	// ExportedCount were exported.
+	TotalCount    int64
+	ExportedCount int64
+}
+
+// ExportEntries exports the entries of a completed analysis matching q,
+// oldest first, as format "csv" or "json", at most limit of them; zero
+// takes the server's cap. The paging and sort of q are ignored; larger
+// exports are made asynchronously with CreateExport.
+func (c *Client) ExportEntries(ctx context.Context, jobID string, q SearchQuery, format string, limit int) (*EntryExport, error) {
+	params := url.Values{"format": {format}}
+	setParam(params, "q", q.Query)
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if q.IncludeNoise {
+		params.Set("include_noise", "true")
+	}
+	setTime(params, "time_from", q.TimeFrom)
+	setTime(params, "time_to", q.TimeTo)
+
+	req := &request{method: http.MethodGet, path: pathf("/analysis/%s/search/export", jobID), query: params}
+	body, header, err := c.doRaw(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	export := &EntryExport{Data: body}
+	export.TotalCount, _ = strconv.ParseInt(header.Get("X-Total-Count"), 10, 64)
+	export.ExportedCount, _ = strconv.ParseInt(header.Get("X-Exported-Count"), 10, 64)
+	return export, nil
+}
+
+// CreateExport starts an asynchronous export of the entries of a completed
+// analysis matching body.Query. The export is returned pending; GetExport
+// follows it until it completes.
+func (c *Client) CreateExport(ctx context.Context, jobID string, body CreateExportRequest) (*SearchExport, error) {
+	req := &request{method: http.MethodPost, path: pathf("/analysis/%s/exports", jobID), idempotent: true}
+	if err := req.setJSON(body); err != nil {
+		return nil, err
+	}
+	var export SearchExport
+	if err := c.doJSON(ctx, req, &export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ListExports lists the exports of the tenant.
+func (c *Client) ListExports(ctx context.Context) ([]SearchExport, error) {
+	var resp struct {
+		Exports []SearchExport `json:"exports"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/exports"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Exports, nil
+}
+
+// GetExport returns an export, with the URL of its file once complete.
+func (c *Client) GetExport(ctx context.Context, exportID string) (*SearchExport, error) {
+	var export SearchExport
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/exports/%s", exportID)}, &export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ExportProvenance returns the provenance of a completed export: what it
+// was generated from, by whom and when, and the SHA-256 of its file.
+func (c *Client) ExportProvenance(ctx context.Context, exportID string) (*ArtifactProvenance, error) {
+	var prov ArtifactProvenance
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/exports/%s/provenance", exportID)}, &prov); err != nil {
+		return nil, err
+	}
+	return &prov, nil
+}
+
+// RegenerateExport starts a new export replaying the provenance of a
+// completed one. While the analyses it read are unchanged, the new file is
+// byte for byte the original and their content hashes match.
+func (c *Client) RegenerateExport(ctx context.Context, exportID string) (*SearchExport, error) {
+	var export SearchExport
+	req := &request{method: http.MethodPost, path: pathf("/exports/%s/regenerate", exportID), idempotent: true}
+	if err := c.doJSON(ctx, req, &export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// DownloadExport copies the file of a completed export to w through the
+// API, without following its pre-signed URL.
+func (c *Client) DownloadExport(ctx context.Context, exportID string, w io.Writer) (int64, error) {
+	req := &request{
+		method: http.MethodGet,
+		path:   pathf("/exports/%s/download", exportID),
+		header: http.Header{"Accept": {"*/*"}},
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("client: download export %s: %w", exportID, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportEntries(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/analysis/j1/search/export", r.URL.Path)
+		assert.Equal(t, "format=csv&include_noise=true&limit=100&q=user%3ADemo&time_to=2026-03-01T10%3A00%3A00Z", r.URL.RawQuery)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("X-Total-Count", "250")
+		w.Header().Set("X-Exported-Count", "100")
+		_, _ = w.Write([]byte("line_number\n1\n"))
+	}))
+
+	to := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	export, err := c.ExportEntries(context.Background(), "j1", SearchQuery{Query: "user:Demo", TimeTo: &to, IncludeNoise: true}, "csv", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "line_number\n1\n", string(export.Data))
+	assert.Equal(t, int64(250), export.TotalCount)
+	assert.Equal(t, int64(100), export.ExportedCount)
+}
+
+func TestAsyncExports(t *testing.T) {
+	const exportID = "00000000-0000-0000-0000-0000000000e1"
+	var body map[string]any
+	var key string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/analysis/j1/exports":
+			key = r.Header.Get("Idempotency-Key")
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			writeJSON(w, http.StatusAccepted, map[string]any{"id": exportID, "status": "pending"})
+		case "/api/v1/exports":
+			writeJSON(w, http.StatusOK, map[string]any{"exports": []any{map[string]any{"id": exportID}}})
+		case "/api/v1/exports/" + exportID:
+			writeJSON(w, http.StatusOK, map[string]any{"id": exportID, "status": "complete", "download_url": "/api/v1/exports/" + exportID + "/download"})
+		case "/api/v1/exports/" + exportID + "/download":
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = w.Write([]byte("a,b\n"))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	ctx := context.Background()
+
+	created, err := c.CreateExport(ctx, "j1", CreateExportRequest{Format: "csv", Query: SearchQuery{Query: "status:error", PageSize: 50}})
+	require.NoError(t, err)
+	assert.Equal(t, ExportStatus("pending"), created.Status)
+	assert.NotEmpty(t, key, "creating an export is idempotent")
+	assert.Equal(t, map[string]any{
+		"format": "csv",
+		"query":  map[string]any{"query": "status:error", "page": float64(0), "page_size": float64(50)},
+	}, body)
+
+	exports, err := c.ListExports(ctx)
+	require.NoError(t, err)
+	assert.Len(t, exports, 1)
+
+	export, err := c.GetExport(ctx, exportID)
+	require.NoError(t, err)
+	assert.Equal(t, ExportStatus("complete"), export.Status)
+
+	var buf bytes.Buffer
+	n, err := c.DownloadExport(ctx, exportID, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	assert.Equal(t, "a,b\n", buf.String())
+}
+
+func TestExportProvenance(t *testing.T) {
+	const exportID = "00000000-0000-0000-0000-0000000000e1"
+	var key string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/exports/"+exportID+"/provenance":
+			writeJSON(w, http.StatusOK, map[string]any{
+				"format": "csv", "parser_version": "2", "generated_by": "u1", "generated_at": "2026-03-01T10:00:00Z",
+				"analyses":     []any{map[string]any{"job_id": "00000000-0000-0000-0000-0000000000a1"}},
+				"content_hash": "abc",
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/exports/"+exportID+"/regenerate":
+			key = r.Header.Get("Idempotency-Key")
+			writeJSON(w, http.StatusAccepted, map[string]any{"id": "00000000-0000-0000-0000-0000000000e2", "status": "queued", "regenerated_from": exportID})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	ctx := context.Background()
+
+	prov, err := c.ExportProvenance(ctx, exportID)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", prov.ContentHash)
+	assert.Equal(t, "u1", prov.GeneratedBy)
+	require.Len(t, prov.Analyses, 1)
+
+	export, err := c.RegenerateExport(ctx, exportID)
+	require.NoError(t, err)
+	require.NotNil(t, export.RegeneratedFrom)
+	assert.Equal(t, exportID, export.RegeneratedFrom.String())
+	assert.NotEmpty(t, key, "regenerating an export is idempotent")
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// UploadFile uploads a log file read from r, streaming it to the server as
+// it is read. sourceType names the kind of log, or is empty for the server
+// to detect it. Uploads are not retried, since r cannot be read again.
+func (c *Client) UploadFile(ctx context.Context, filename string, r io.Reader, sourceType LogSourceType) (*LogFile, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeUpload(mw, filename, r, sourceType))
+	}()
+	// Unblock the writer when the request ends before reading the body.
+	defer pr.Close()
+
+	req := &request{
+		method: http.MethodPost,
+		path:   "/files/upload",
+		header: http.Header{"Content-Type": {mw.FormDataContentType()}},
+		stream: pr,
+	}
+	var file LogFile
+	if err := c.doJSON(ctx, req, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// writeUpload writes the multipart body of an upload.
+func writeUpload(mw *multipart.Writer, filename string, r io.Reader, sourceType LogSourceType) error {
+	if sourceType != "" {
+		if err := mw.WriteField("source_type", string(sourceType)); err != nil {
+			return err
+		}
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// ListFiles lists the uploaded files of the tenant.
+func (c *Client) ListFiles(ctx context.Context) ([]LogFile, error) {
+	var resp struct {
+		Files []LogFile `json:"files"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/files"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
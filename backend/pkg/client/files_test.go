@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadFile_Streams(t *testing.T) {
+	firstChunk := make(chan struct{})
+	var sourceType, filename, content string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files/upload", r.URL.Path)
+		mr, err := r.MultipartReader()
+		require.NoError(t, err)
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			switch part.FormName() {
+			case "source_type":
+				b, _ := io.ReadAll(part)
+				sourceType = string(b)
+			case "file":
+				filename = part.FileName()
+				buf := make([]byte, len("first line\n"))
+				_, err := io.ReadFull(part, buf)
+				require.NoError(t, err)
+				// The rest of the file is only written once the server
+				// has read the start of it.
+				close(firstChunk)
+				rest, _ := io.ReadAll(part)
+				content = string(buf) + string(rest)
+			}
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{
+			"id":         "00000000-0000-0000-0000-0000000000f1",
+			"filename":   filename,
+			"size_bytes": len(content),
+		})
+	}))
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("first line\n"))
+		select {
+		case <-firstChunk:
+			_, _ = pw.Write([]byte("second line\n"))
+			_ = pw.Close()
+		case <-time.After(5 * time.Second):
+			_ = pw.CloseWithError(errors.New("upload was buffered, not streamed"))
+		}
+	}()
+
+	file, err := c.UploadFile(context.Background(), "arapi.log", pr, "ar_server")
+	require.NoError(t, err)
+	assert.Equal(t, "00000000-0000-0000-0000-0000000000f1", file.ID.String())
+	assert.Equal(t, "arapi.log", file.Filename)
+	assert.Equal(t, int64(len("first line\nsecond line\n")), file.SizeBytes)
+	assert.Equal(t, "ar_server", sourceType)
+	assert.Equal(t, "first line\nsecond line\n", content)
+}
+
+func TestUploadFile_NotRetried(t *testing.T) {
+	calls := 0
+	c, waits := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "too many uploads")
+	}))
+
+	_, err := c.UploadFile(context.Background(), "arapi.log", strings.NewReader("line\n"), "")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, *waits)
+}
+
+func TestUploadFile_ReaderError(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		writeJSON(w, http.StatusCreated, map[string]any{})
+	}))
+
+	_, err := c.UploadFile(context.Background(), "arapi.log", io.MultiReader(strings.NewReader("line\n"), errReader{}), "")
+	assert.Error(t, err)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("disk gone") }
+
+func TestListFiles(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/files", r.URL.Path)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"files":      []map[string]any{{"filename": "a.log"}, {"filename": "b.log"}},
+			"pagination": Pagination{Page: 1, PageSize: 2, TotalCount: 2, TotalPages: 1},
+		})
+	}))
+
+	files, err := c.ListFiles(context.Background())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "b.log", files[1].Filename)
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// ThresholdRules lists the threshold rules of the tenant.
+func (c *Client) ThresholdRules(ctx context.Context) ([]ThresholdRule, error) {
+	var resp struct {
+		Rules []ThresholdRule `json:"rules"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/threshold-rules"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Rules, nil
+}
+
+// CreateThresholdRule creates a threshold rule.
+func (c *Client) CreateThresholdRule(ctx context.Context, body ThresholdRuleRequest) (*ThresholdRule, error) {
+	var rule ThresholdRule
+	if err := c.sendJSON(ctx, http.MethodPost, "/threshold-rules", body, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateThresholdRule replaces a threshold rule.
+func (c *Client) UpdateThresholdRule(ctx context.Context, ruleID string, body ThresholdRuleRequest) (*ThresholdRule, error) {
+	var rule ThresholdRule
+	if err := c.sendJSON(ctx, http.MethodPut, pathf("/threshold-rules/%s", ruleID), body, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteThresholdRule deletes a threshold rule.
+func (c *Client) DeleteThresholdRule(ctx context.Context, ruleID string) error {
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: pathf("/threshold-rules/%s", ruleID)}, nil)
+}
+
+// Violations returns the threshold rules a completed analysis violated.
+func (c *Client) Violations(ctx context.Context, jobID string) (*Violations, error) {
+	var v Violations
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/violations", jobID)}, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// IngestionFilters lists the ingestion filter rules of the tenant.
+func (c *Client) IngestionFilters(ctx context.Context) ([]IngestionFilterRule, error) {
+	var resp struct {
+		Rules []IngestionFilterRule `json:"rules"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/ingestion-filters"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Rules, nil
+}
+
+// CreateIngestionFilter creates an ingestion filter rule.
+func (c *Client) CreateIngestionFilter(ctx context.Context, body IngestionFilterRequest) (*IngestionFilterRule, error) {
+	var rule IngestionFilterRule
+	if err := c.sendJSON(ctx, http.MethodPost, "/ingestion-filters", body, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateIngestionFilter replaces an ingestion filter rule.
+func (c *Client) UpdateIngestionFilter(ctx context.Context, ruleID string, body IngestionFilterRequest) (*IngestionFilterRule, error) {
+	var rule IngestionFilterRule
+	if err := c.sendJSON(ctx, http.MethodPut, pathf("/ingestion-filters/%s", ruleID), body, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteIngestionFilter deletes an ingestion filter rule.
+func (c *Client) DeleteIngestionFilter(ctx context.Context, ruleID string) error {
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: pathf("/ingestion-filters/%s", ruleID)}, nil)
+}
+
+// DryRunIngestionFilters counts the entries of a completed analysis each
+// of body.Rules would match, without saving them.
+func (c *Client) DryRunIngestionFilters(ctx context.Context, body DryRunRequest) (*DryRunResponse, error) {
+	var resp DryRunResponse
+	if err := c.sendJSON(ctx, http.MethodPost, "/ingestion-filters/dry-run", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AnalysisLinks lists the tickets and changes linked to an analysis.
+func (c *Client) AnalysisLinks(ctx context.Context, jobID string) ([]AnalysisLink, error) {
+	var resp struct {
+		Links []AnalysisLink `json:"links"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/links", jobID)}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Links, nil
+}
+
+// CreateAnalysisLink links a ticket or change to an analysis.
+func (c *Client) CreateAnalysisLink(ctx context.Context, jobID string, body AnalysisLinkRequest) (*AnalysisLink, error) {
+	var link AnalysisLink
+	if err := c.sendJSON(ctx, http.MethodPost, pathf("/analysis/%s/links", jobID), body, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// DeleteAnalysisLink removes a link of an analysis.
+func (c *Client) DeleteAnalysisLink(ctx context.Context, jobID, linkID string) error {
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: pathf("/analysis/%s/links/%s", jobID, linkID)}, nil)
+}
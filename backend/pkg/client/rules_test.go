@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	var method, path string
+	var body map[string]any
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, body = r.Method, r.URL.Path, nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/threshold-rules", r.URL.Path == "/api/v1/ingestion-filters":
+			if r.Method == http.MethodPost {
+				writeJSON(w, http.StatusCreated, map[string]any{"name": body["name"]})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"rules": []any{map[string]any{"name": "slow"}}})
+		case r.URL.Path == "/api/v1/analysis/j1/violations":
+			writeJSON(w, http.StatusOK, map[string]any{"job_id": "j1", "evaluated": true, "violations": []any{}, "total": 0})
+		case r.URL.Path == "/api/v1/ingestion-filters/dry-run":
+			writeJSON(w, http.StatusOK, map[string]any{"job_id": "j1", "total_entries": 100, "results": []any{map[string]any{"rule": map[string]any{"name": "noise"}, "matched": 40}}})
+		case r.URL.Path == "/api/v1/analysis/j1/links":
+			if r.Method == http.MethodPost {
+				writeJSON(w, http.StatusCreated, map[string]any{"external_key": "INC-1"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"links": []any{map[string]any{"external_key": "INC-1"}}})
+		default:
+			writeJSON(w, http.StatusOK, map[string]any{"name": body["name"]})
+		}
+	}))
+	ctx := context.Background()
+	enabled := true
+
+	rules, err := c.ThresholdRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "slow", rules[0].Name)
+
+	rule, err := c.CreateThresholdRule(ctx, ThresholdRuleRequest{Name: "slow", Metric: "p95_ms", Comparator: "gt", Value: 500, Enabled: &enabled})
+	require.NoError(t, err)
+	assert.Equal(t, "slow", rule.Name)
+	assert.Equal(t, map[string]any{
+		"name": "slow", "scope": "", "scope_value": "", "metric": "p95_ms", "comparator": "gt",
+		"value": float64(500), "severity": "", "enabled": true,
+	}, body)
+
+	_, err = c.UpdateThresholdRule(ctx, "r1", ThresholdRuleRequest{Name: "slower"})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/api/v1/threshold-rules/r1", path)
+
+	require.NoError(t, c.DeleteThresholdRule(ctx, "r1"))
+	assert.Equal(t, "/api/v1/threshold-rules/r1", path)
+
+	violations, err := c.Violations(ctx, "j1")
+	require.NoError(t, err)
+	assert.True(t, violations.Evaluated)
+
+	filters, err := c.IngestionFilters(ctx)
+	require.NoError(t, err)
+	assert.Len(t, filters, 1)
+
+	_, err = c.CreateIngestionFilter(ctx, IngestionFilterRequest{Name: "noise", Field: "user", Operator: "equals", Value: "Remedy Application Service", Action: "drop"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/ingestion-filters", path)
+	assert.Equal(t, "drop", body["action"])
+
+	_, err = c.UpdateIngestionFilter(ctx, "f1", IngestionFilterRequest{Name: "noise"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/ingestion-filters/f1", path)
+
+	require.NoError(t, c.DeleteIngestionFilter(ctx, "f1"))
+	assert.Equal(t, http.MethodDelete, method)
+
+	dry, err := c.DryRunIngestionFilters(ctx, DryRunRequest{JobID: "j1", Rules: []IngestionFilterRequest{{Name: "noise"}}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), dry.Results[0].Matched)
+	assert.Equal(t, "j1", body["job_id"])
+
+	links, err := c.AnalysisLinks(ctx, "j1")
+	require.NoError(t, err)
+	assert.Equal(t, "INC-1", links[0].ExternalKey)
+
+	link, err := c.CreateAnalysisLink(ctx, "j1", AnalysisLinkRequest{Type: "incident", ExternalKey: "INC-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "INC-1", link.ExternalKey)
+
+	require.NoError(t, c.DeleteAnalysisLink(ctx, "j1", "l1"))
+	assert.Equal(t, "/api/v1/analysis/j1/links/l1", path)
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Search searches the entries of a completed analysis. An empty q.Query
+// matches every entry; zero paging takes the server's defaults.
+func (c *Client) Search(ctx context.Context, jobID string, q SearchQuery, opts SearchOptions) (*SearchResponse, error) {
+	params := searchParams(q)
+	if opts.IncludeHistogram {
+		params.Set("include_histogram", "true")
+	}
+	if opts.Suggest {
+		params.Set("suggest", "true")
+	}
+	for _, col := range opts.Computed {
+		params.Add("computed", col.Name+":"+col.Expr)
+	}
+	var resp SearchResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/search", jobID), query: params}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// searchParams encodes q as the query parameters of a search.
+func searchParams(q SearchQuery) url.Values {
+	params := url.Values{}
+	setParam(params, "q", q.Query)
+	if q.Page > 0 {
+		params.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PageSize > 0 {
+		params.Set("page_size", strconv.Itoa(q.PageSize))
+	}
+	setParam(params, "sort_by", q.SortBy)
+	setParam(params, "sort_order", q.SortOrder)
+	setParam(params, "secondary_sort_by", q.SecondarySortBy)
+	setParam(params, "secondary_sort_order", q.SecondarySortOrder)
+	if q.IncludeNoise {
+		params.Set("include_noise", "true")
+	}
+	for _, t := range q.LogTypes {
+		params.Add("log_type", t)
+	}
+	for _, u := range appendNonEmpty(q.Users, q.UserFilter) {
+		params.Add("user", u)
+	}
+	for _, queue := range appendNonEmpty(q.Queues, q.QueueFilter) {
+		params.Add("queue", queue)
+	}
+	setTime(params, "time_from", q.TimeFrom)
+	setTime(params, "time_to", q.TimeTo)
+	return params
+}
+
+func appendNonEmpty(values []string, v string) []string {
+	if v == "" {
+		return values
+	}
+	return append(values[:len(values):len(values)], v)
+}
+
+// SavedSearches lists the saved searches of the user.
+func (c *Client) SavedSearches(ctx context.Context) ([]SavedSearch, error) {
+	var searches []SavedSearch
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/search/saved"}, &searches); err != nil {
+		return nil, err
+	}
+	return searches, nil
+}
+
+// SaveSearch saves a search of the user.
+func (c *Client) SaveSearch(ctx context.Context, body SavedSearchRequest) (*SavedSearch, error) {
+	var search SavedSearch
+	if err := c.sendJSON(ctx, http.MethodPost, "/search/saved", body, &search); err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
+
+// DeleteSavedSearch deletes a saved search of the user.
+func (c *Client) DeleteSavedSearch(ctx context.Context, searchID string) error {
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: pathf("/search/saved/%s", searchID)}, nil)
+}
+
+// SearchHistory lists the recent searches of the user.
+func (c *Client) SearchHistory(ctx context.Context) ([]SearchHistoryEntry, error) {
+	var history []SearchHistoryEntry
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/search/history"}, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	var query url.Values
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/analysis/j1/search", r.URL.Path)
+		query = r.URL.Query()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"results":     []any{map[string]any{"id": "e1", "score": 1.5, "fields": map[string]any{"user": "Demo"}, "computed": map[string]any{"slow": true}}},
+			"total":       1,
+			"page":        2,
+			"page_size":   25,
+			"total_pages": 1,
+			"facets":      map[string]any{"user": []any{map[string]any{"value": "Demo", "count": 1}}},
+			"took_ms":     4,
+			"suggestions": []any{map[string]any{"field": "form", "kql": "form:HPD", "predicted_count": 1, "share": 1, "reason": "r"}},
+			"query_interpretation": map[string]any{
+				"terms": []any{map[string]any{"term": "timeout", "match": "raw_text"}},
+			},
+		})
+	}))
+
+	from := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	resp, err := c.Search(context.Background(), "j1", SearchQuery{
+		Query:        "user:Demo timeout",
+		LogTypes:     []string{"API", "SQL"},
+		TimeFrom:     &from,
+		UserFilter:   "Demo",
+		Queues:       []string{"Fast"},
+		SortBy:       "duration_ms",
+		SortOrder:    "desc",
+		Page:         2,
+		PageSize:     25,
+		IncludeNoise: true,
+	}, SearchOptions{
+		IncludeHistogram: true,
+		Suggest:          true,
+		Computed:         []ComputedColumn{{Name: "slow", Expr: "duration_ms > 1000"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, url.Values{
+		"q":                 {"user:Demo timeout"},
+		"log_type":          {"API", "SQL"},
+		"time_from":         {"2026-03-01T10:00:00Z"},
+		"user":              {"Demo"},
+		"queue":             {"Fast"},
+		"sort_by":           {"duration_ms"},
+		"sort_order":        {"desc"},
+		"page":              {"2"},
+		"page_size":         {"25"},
+		"include_noise":     {"true"},
+		"include_histogram": {"true"},
+		"suggest":           {"true"},
+		"computed":          {"slow:duration_ms > 1000"},
+	}, query)
+
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "Demo", resp.Results[0].Fields["user"])
+	assert.Equal(t, true, resp.Results[0].Computed["slow"])
+	assert.Equal(t, []FacetEntry{{Value: "Demo", Count: 1}}, resp.Facets["user"])
+	assert.Equal(t, "form:HPD", resp.Suggestions[0].KQL)
+	assert.Equal(t, "raw_text", resp.QueryInterpretation.Terms[0].Match)
+}
+
+func TestSearchParams_DefaultsOmitted(t *testing.T) {
+	assert.Empty(t, searchParams(SearchQuery{}))
+
+	users := []string{"a"}
+	params := searchParams(SearchQuery{Users: users, UserFilter: "b"})
+	assert.Equal(t, []string{"a", "b"}, params["user"])
+	assert.Equal(t, []string{"a"}, users, "the query's users are left alone")
+}
+
+func TestSavedSearches(t *testing.T) {
+	var method, path string
+	var body map[string]any
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, body = r.Method, r.URL.Path, nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodPost:
+			writeJSON(w, http.StatusCreated, map[string]any{"name": "slow", "kql_query": "duration_ms:>1000"})
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/search/history":
+			writeJSON(w, http.StatusOK, []any{map[string]any{"kql_query": "user:Demo"}})
+		default:
+			writeJSON(w, http.StatusOK, []any{map[string]any{"name": "slow"}})
+		}
+	}))
+	ctx := context.Background()
+
+	saved, err := c.SaveSearch(ctx, SavedSearchRequest{Name: "slow", KQLQuery: "duration_ms:>1000", Filters: json.RawMessage(`{"log_type":"API"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "slow", saved.Name)
+	assert.Equal(t, map[string]any{"name": "slow", "kql_query": "duration_ms:>1000", "filters": map[string]any{"log_type": "API"}}, body)
+
+	searches, err := c.SavedSearches(ctx)
+	require.NoError(t, err)
+	assert.Len(t, searches, 1)
+
+	require.NoError(t, c.DeleteSavedSearch(ctx, "s1"))
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "/api/v1/search/saved/s1", path)
+
+	history, err := c.SearchHistory(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "user:Demo", history[0].KQLQuery)
+}
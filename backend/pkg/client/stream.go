@@ -0,0 +1,440 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Types of the messages a stream receives. EventReconnected is made by the
+// client, not the server: it follows a reconnection, after which messages
+// sent while the stream was down are lost.
+const (
+	EventJobProgress       = "job_progress"
+	EventJobComplete       = "job_complete"
+	EventLiveTailEntry     = "live_tail_entry"
+	EventInvestigation     = "investigation_updated"
+	EventDashboardSection  = "dashboard_section"
+	EventDashboardComplete = "dashboard_complete"
+	EventError             = "error"
+	EventPong              = "pong"
+	EventReconnected       = "reconnected"
+)
+
+const (
+	// streamProtocols are the WebSocket protocol versions the client
+	// speaks.
+	streamProtocols = "1,2"
+
+	streamWriteWait = 10 * time.Second
+	// streamReadWait is how long the stream waits for a message or a ping
+	// of the server, which pings every 30s, before reconnecting.
+	streamReadWait = 90 * time.Second
+
+	streamBufferSize = 256
+)
+
+// Event is a message of the server on a stream.
+type Event struct {
+	Type string `json:"type"`
+	// Version is the protocol version of the connection, 0 for version 1.
+	Version int             `json:"version,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Decode decodes the payload of the event into its type: *JobProgress,
+// *AnalysisJob, *LogEntry, *InvestigationEvent, *DashboardSection,
+// *DashboardComplete or *StreamError. Events without a payload decode to
+// nil.
+func (e Event) Decode() (any, error) {
+	var v any
+	switch e.Type {
+	case EventJobProgress:
+		v = new(JobProgress)
+	case EventJobComplete:
+		v = new(AnalysisJob)
+	case EventLiveTailEntry:
+		v = new(LogEntry)
+	case EventInvestigation:
+		v = new(InvestigationEvent)
+	case EventDashboardSection:
+		v = new(DashboardSection)
+	case EventDashboardComplete:
+		v = new(DashboardComplete)
+	case EventError:
+		v = new(StreamError)
+	case EventPong, EventReconnected:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("client: unknown event type %q", e.Type)
+	}
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return nil, fmt.Errorf("client: decode %s event: %w", e.Type, err)
+	}
+	return v, nil
+}
+
+// JobProgress is the progress of a running analysis.
+type JobProgress struct {
+	JobID          string `json:"job_id"`
+	Status         string `json:"status"`
+	ProgressPct    int    `json:"progress_pct"`
+	ProcessedLines int64  `json:"processed_lines"`
+	TotalLines     int64  `json:"total_lines"`
+	Message        string `json:"message"`
+	// Queue is set on the updates of queued analyses.
+	Queue *JobQueueEstimate `json:"queue,omitempty"`
+}
+
+// DashboardSection is one section of a streamed dashboard. Payload has the
+// shape of the section's endpoint.
+type DashboardSection struct {
+	JobID   string          `json:"job_id"`
+	Section string          `json:"section"`
+	Source  string          `json:"source"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// DashboardComplete ends a streamed dashboard: the sections sent and those
+// that failed.
+type DashboardComplete struct {
+	JobID    string                  `json:"job_id"`
+	Sections []string                `json:"sections"`
+	Failed   []DashboardSectionError `json:"failed"`
+}
+
+// DashboardSectionError is a section of a streamed dashboard that could not
+// be loaded.
+type DashboardSectionError struct {
+	Section string `json:"section"`
+	Error   string `json:"error"`
+}
+
+// StreamError is an error the server reports on a stream, such as a
+// subscription it refused.
+type StreamError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// clientMessage is a message to the server.
+type clientMessage struct {
+	Type    string `json:"type"`
+	Payload any    `json:"payload,omitempty"`
+}
+
+// Stream is a WebSocket connection to the server delivering the events of
+// its subscriptions. When the connection drops it reconnects with backoff
+// and subscribes again; subscribing while it is down takes effect once it
+// is back. Its methods are safe for concurrent use.
+type Stream struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	events chan Event
+
+	// mu guards conn, subs and err, and serialises writes.
+	mu   sync.Mutex
+	conn *websocket.Conn
+	// subs are the subscribe messages to send again on reconnection, by
+	// subscription. Dashboard subscriptions leave on dashboard_complete.
+	subs map[string]clientMessage
+	err  error
+}
+
+// Stream opens a stream of server events. It ends, closing Events, when
+// ctx is done or Close is called, or when reconnecting fails for good,
+// which Err then reports.
+func (c *Client) Stream(ctx context.Context) (*Stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		c:      c,
+		ctx:    ctx,
+		cancel: cancel,
+		events: make(chan Event, streamBufferSize),
+		subs:   make(map[string]clientMessage),
+	}
+	conn, err := s.dial()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.conn = conn
+	go s.run(conn)
+	go func() {
+		// Unblock the read of the connection when ctx ends the stream.
+		<-ctx.Done()
+		_ = s.closeConn()
+	}()
+	return s, nil
+}
+
+// Events delivers the events of the stream until it ends.
+func (s *Stream) Events() <-chan Event { return s.events }
+
+// Err is the error that ended the stream, nil when it was closed.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the stream.
+func (s *Stream) Close() error {
+	s.cancel()
+	return s.closeConn()
+}
+
+// closeConn closes the current connection, telling the server first.
+func (s *Stream) closeConn() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	_ = s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// SubscribeJobProgress subscribes to the progress of an analysis.
+func (s *Stream) SubscribeJobProgress(jobID string) error {
+	return s.subscribe("job:"+jobID, clientMessage{Type: "subscribe_job_progress", Payload: map[string]string{"job_id": jobID}})
+}
+
+// UnsubscribeJobProgress ends a subscription to the progress of an
+// analysis.
+func (s *Stream) UnsubscribeJobProgress(jobID string) error {
+	return s.unsubscribe("job:"+jobID, clientMessage{Type: "unsubscribe_job_progress", Payload: map[string]string{"job_id": jobID}})
+}
+
+// SubscribeLiveTail subscribes to the new entries of a log type.
+func (s *Stream) SubscribeLiveTail(logType string) error {
+	return s.subscribe("tail:"+logType, clientMessage{Type: "subscribe_live_tail", Payload: map[string]string{"log_type": logType}})
+}
+
+// UnsubscribeLiveTail ends a subscription to the entries of a log type.
+func (s *Stream) UnsubscribeLiveTail(logType string) error {
+	return s.unsubscribe("tail:"+logType, clientMessage{Type: "unsubscribe_live_tail", Payload: map[string]string{"log_type": logType}})
+}
+
+// SubscribeInvestigations subscribes to the investigation changes of the
+// tenant.
+func (s *Stream) SubscribeInvestigations() error {
+	return s.subscribe("investigations", clientMessage{Type: "subscribe_investigations"})
+}
+
+// UnsubscribeInvestigations ends the subscription to investigation
+// changes.
+func (s *Stream) UnsubscribeInvestigations() error {
+	return s.unsubscribe("investigations", clientMessage{Type: "unsubscribe_investigations"})
+}
+
+// SubscribeDashboard asks for the dashboard of a completed analysis, sent
+// section by section and ended by a dashboard_complete event. A dashboard
+// cut short by a reconnection is asked for again.
+func (s *Stream) SubscribeDashboard(jobID string) error {
+	return s.subscribe("dashboard:"+jobID, clientMessage{Type: "subscribe_dashboard", Payload: map[string]string{"job_id": jobID}})
+}
+
+// UnsubscribeDashboard stops a streamed dashboard.
+func (s *Stream) UnsubscribeDashboard(jobID string) error {
+	return s.unsubscribe("dashboard:"+jobID, clientMessage{Type: "unsubscribe_dashboard", Payload: map[string]string{"job_id": jobID}})
+}
+
+// Ping asks the server for a pong event.
+func (s *Stream) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(clientMessage{Type: "ping"})
+}
+
+func (s *Stream) subscribe(key string, msg clientMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[key] = msg
+	return s.write(msg)
+}
+
+func (s *Stream) unsubscribe(key string, msg clientMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, key)
+	return s.write(msg)
+}
+
+// write sends msg on the current connection; s.mu must be held. Nothing is
+// sent while the stream reconnects, and a failed write drops the
+// connection to reconnect: subscriptions are sent again either way.
+func (s *Stream) write(msg clientMessage) error {
+	if s.ctx.Err() != nil {
+		return errors.New("client: stream is closed")
+	}
+	if s.conn == nil {
+		return nil
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		_ = s.conn.Close()
+	}
+	return nil
+}
+
+// dial opens a connection to the server's WebSocket endpoint.
+func (s *Stream) dial() (*websocket.Conn, error) {
+	u := *s.c.baseURL
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	target := u.String() + apiPrefix + "/ws?protocol=" + streamProtocols
+
+	header := http.Header{}
+	header.Set("User-Agent", s.c.userAgent)
+	s.c.auth(header)
+	dialer := *websocket.DefaultDialer
+	if t, ok := s.c.httpClient.Transport.(*http.Transport); ok && t != nil {
+		dialer.Proxy = t.Proxy
+		dialer.TLSClientConfig = t.TLSClientConfig
+	}
+
+	conn, resp, err := dialer.DialContext(s.ctx, target, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= 400 {
+			return nil, decodeError(resp)
+		}
+		return nil, fmt.Errorf("client: dial stream: %w", err)
+	}
+	conn.SetReadLimit(1 << 24)
+	conn.SetPingHandler(func(data string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(streamReadWait))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(streamWriteWait))
+	})
+	return conn, nil
+}
+
+// run reads the stream's connections, reconnecting when one drops, until
+// the stream ends.
+func (s *Stream) run(conn *websocket.Conn) {
+	defer close(s.events)
+	defer s.cancel()
+	for {
+		s.read(conn)
+		if s.ctx.Err() != nil {
+			return
+		}
+		var err error
+		if conn, err = s.reconnect(); err != nil {
+			if s.ctx.Err() == nil {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+			}
+			return
+		}
+		if !s.deliver(Event{Type: EventReconnected}) {
+			return
+		}
+	}
+}
+
+// read delivers the events of conn until it fails.
+func (s *Stream) read(conn *websocket.Conn) {
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(streamReadWait))
+		var ev Event
+		if err := conn.ReadJSON(&ev); err != nil {
+			_ = conn.Close()
+			return
+		}
+		if ev.Type == EventDashboardComplete {
+			var done DashboardComplete
+			if json.Unmarshal(ev.Payload, &done) == nil {
+				s.mu.Lock()
+				delete(s.subs, "dashboard:"+done.JobID)
+				s.mu.Unlock()
+			}
+		}
+		if !s.deliver(ev) {
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+// deliver sends ev on Events, reporting false when the stream ended first.
+func (s *Stream) deliver(ev Event) bool {
+	select {
+	case s.events <- ev:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// reconnect dials the server with backoff until it connects and sends the
+// subscriptions again. Errors the server answers the handshake with other
+// than 429 and 5xx, such as an expired token, are final.
+func (s *Stream) reconnect() (*websocket.Conn, error) {
+	s.mu.Lock()
+	s.conn = nil
+	s.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if err := s.c.sleep(s.ctx, s.c.backoff(min(attempt, 16))); err != nil {
+			return nil, err
+		}
+		conn, err := s.dial()
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < 500 {
+				return nil, err
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		if s.ctx.Err() != nil {
+			// Closed while dialling.
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil, s.ctx.Err()
+		}
+		s.conn = conn
+		var werr error
+		for _, key := range slices.Sorted(maps.Keys(s.subs)) {
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if werr = conn.WriteJSON(s.subs[key]); werr != nil {
+				break
+			}
+		}
+		if werr != nil {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+		if werr == nil {
+			return conn, nil
+		}
+		_ = conn.Close()
+	}
+}
+
+// subscriptionKeys lists the subscriptions the stream sends again on
+// reconnection.
+func (s *Stream) subscriptionKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(maps.Keys(s.subs))
+}
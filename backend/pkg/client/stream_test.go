@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsConn is one connection a test server accepted.
+type wsConn struct {
+	conn     *websocket.Conn
+	query    string
+	userID   string
+	received chan clientMessageIn
+}
+
+// clientMessageIn is a message of the client as the server reads it.
+type clientMessageIn struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// wsServer accepts WebSocket connections on /api/v1/ws, handing each to
+// the test. reject, when it returns a status, refuses the handshake.
+func wsServer(t *testing.T, reject func(n int) int) (http.Handler, chan *wsConn) {
+	conns := make(chan *wsConn, 4)
+	var n atomic.Int32
+	upgrader := websocket.Upgrader{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/ws", r.URL.Path)
+		if reject != nil {
+			if status := reject(int(n.Add(1))); status != 0 {
+				writeError(w, status, "UNAUTHORIZED", "token expired")
+				return
+			}
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := &wsConn{conn: conn, query: r.URL.RawQuery, userID: r.Header.Get("X-Dev-User-ID"), received: make(chan clientMessageIn, 16)}
+		go func() {
+			defer close(c.received)
+			for {
+				var msg clientMessageIn
+				if err := conn.ReadJSON(&msg); err != nil {
+					return
+				}
+				c.received <- msg
+			}
+		}()
+		conns <- c
+	}), conns
+}
+
+func nextConn(t *testing.T, conns chan *wsConn) *wsConn {
+	t.Helper()
+	select {
+	case c := <-conns:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection")
+		return nil
+	}
+}
+
+func nextMessage(t *testing.T, c *wsConn) clientMessageIn {
+	t.Helper()
+	select {
+	case msg := <-c.received:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+		return clientMessageIn{}
+	}
+}
+
+func nextEvent(t *testing.T, s *Stream) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-s.Events():
+		require.True(t, ok, "stream ended")
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func TestStream_DecodesEvents(t *testing.T) {
+	h, conns := wsServer(t, nil)
+	c, _ := newTestClient(t, h)
+
+	s, err := c.Stream(context.Background())
+	require.NoError(t, err)
+	defer s.Close()
+	server := nextConn(t, conns)
+	assert.Equal(t, "protocol=1,2", server.query)
+	assert.Equal(t, "user-1", server.userID)
+
+	require.NoError(t, s.SubscribeLiveTail("API"))
+	msg := nextMessage(t, server)
+	assert.Equal(t, "subscribe_live_tail", msg.Type)
+	assert.JSONEq(t, `{"log_type":"API"}`, string(msg.Payload))
+
+	send := func(v string) { require.NoError(t, server.conn.WriteMessage(websocket.TextMessage, []byte(v))) }
+	send(`{"type":"live_tail_entry","version":2,"payload":{"entry_id":"e1","user":"Demo"}}`)
+	send(`{"type":"job_progress","version":2,"payload":{"job_id":"j1","status":"parsing","progress_pct":40,"queue":{"position":2}}}`)
+	send(`{"type":"dashboard_section","version":2,"payload":{"job_id":"j1","section":"gaps","source":"cache","payload":{"gaps":[]}}}`)
+	send(`{"type":"error","version":2,"payload":{"code":"forbidden","message":"no access"}}`)
+	send(`{"type":"pong","version":2}`)
+
+	ev := nextEvent(t, s)
+	assert.Equal(t, 2, ev.Version)
+	v, err := ev.Decode()
+	require.NoError(t, err)
+	require.IsType(t, &LogEntry{}, v)
+	assert.Equal(t, "Demo", v.(*LogEntry).User)
+
+	v, err = nextEvent(t, s).Decode()
+	require.NoError(t, err)
+	require.IsType(t, &JobProgress{}, v)
+	assert.Equal(t, 40, v.(*JobProgress).ProgressPct)
+	require.NotNil(t, v.(*JobProgress).Queue)
+
+	v, err = nextEvent(t, s).Decode()
+	require.NoError(t, err)
+	require.IsType(t, &DashboardSection{}, v)
+	assert.JSONEq(t, `{"gaps":[]}`, string(v.(*DashboardSection).Payload))
+
+	v, err = nextEvent(t, s).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, &StreamError{Code: "forbidden", Message: "no access"}, v)
+
+	ev = nextEvent(t, s)
+	assert.Equal(t, EventPong, ev.Type)
+	v, err = ev.Decode()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	_, err = Event{Type: "surprise"}.Decode()
+	assert.Error(t, err)
+}
+
+func TestStream_ReconnectsAndResubscribes(t *testing.T) {
+	h, conns := wsServer(t, nil)
+	c, waits := newTestClient(t, h)
+
+	s, err := c.Stream(context.Background())
+	require.NoError(t, err)
+	first := nextConn(t, conns)
+
+	require.NoError(t, s.SubscribeJobProgress("j1"))
+	require.NoError(t, s.SubscribeDashboard("j2"))
+	require.NoError(t, s.SubscribeInvestigations())
+	require.NoError(t, s.SubscribeLiveTail("SQL"))
+	require.NoError(t, s.UnsubscribeLiveTail("SQL"))
+	for _, want := range []string{"subscribe_job_progress", "subscribe_dashboard", "subscribe_investigations", "subscribe_live_tail", "unsubscribe_live_tail"} {
+		assert.Equal(t, want, nextMessage(t, first).Type)
+	}
+
+	// The dashboard completes, then the connection drops.
+	require.NoError(t, first.conn.WriteJSON(map[string]any{
+		"type": "dashboard_complete", "payload": map[string]any{"job_id": "j2", "sections": []string{"gaps"}, "failed": []any{}},
+	}))
+	assert.Equal(t, EventDashboardComplete, nextEvent(t, s).Type)
+	require.NoError(t, first.conn.Close())
+
+	second := nextConn(t, conns)
+	assert.Equal(t, EventReconnected, nextEvent(t, s).Type)
+	assert.NotEmpty(t, *waits, "reconnection backs off")
+
+	// Only the subscriptions still active are sent again.
+	got := []clientMessageIn{nextMessage(t, second), nextMessage(t, second)}
+	assert.Equal(t, "subscribe_investigations", got[0].Type)
+	assert.Equal(t, "subscribe_job_progress", got[1].Type)
+	assert.JSONEq(t, `{"job_id":"j1"}`, string(got[1].Payload))
+	assert.Equal(t, []string{"investigations", "job:j1"}, s.subscriptionKeys())
+
+	require.NoError(t, second.conn.WriteJSON(map[string]any{"type": "investigation_updated", "payload": map[string]any{"job_id": "00000000-0000-0000-0000-0000000000aa"}}))
+	v, err := nextEvent(t, s).Decode()
+	require.NoError(t, err)
+	assert.IsType(t, &InvestigationEvent{}, v)
+
+	require.NoError(t, s.Ping())
+	assert.Equal(t, "ping", nextMessage(t, second).Type)
+
+	require.NoError(t, s.Close())
+	for range s.Events() {
+	}
+	assert.NoError(t, s.Err())
+	assert.Error(t, s.SubscribeInvestigations(), "a closed stream takes no subscriptions")
+}
+
+func TestStream_ReconnectGivesUpOnRejection(t *testing.T) {
+	h, conns := wsServer(t, func(n int) int {
+		switch n {
+		case 1:
+			return 0
+		case 2:
+			return http.StatusServiceUnavailable
+		default:
+			return http.StatusUnauthorized
+		}
+	})
+	c, waits := newTestClient(t, h)
+
+	s, err := c.Stream(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, nextConn(t, conns).conn.Close())
+
+	for range s.Events() {
+	}
+	var apiErr *APIError
+	require.ErrorAs(t, s.Err(), &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Len(t, *waits, 2, "the 503 is retried, the 401 is not")
+}
+
+func TestStream_DialError(t *testing.T) {
+	h, _ := wsServer(t, func(int) int { return http.StatusUnauthorized })
+	c, _ := newTestClient(t, h)
+
+	_, err := c.Stream(context.Background())
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "UNAUTHORIZED", apiErr.Code)
+}
+
+func TestStream_EndsWithContext(t *testing.T) {
+	h, conns := wsServer(t, nil)
+	c, _ := newTestClient(t, h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := c.Stream(ctx)
+	require.NoError(t, err)
+	nextConn(t, conns)
+
+	cancel()
+	select {
+	case _, ok := <-s.Events():
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end")
+	}
+	assert.NoError(t, s.Err())
+}
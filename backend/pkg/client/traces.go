@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Entry returns an entry of a completed analysis.
+func (c *Client) Entry(ctx context.Context, jobID, entryID string) (*LogEntry, error) {
+	var entry LogEntry
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/entries/%s", jobID, entryID)}, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ResolveEntry returns the ID of the entry at a line of a file of a
+// completed analysis; fileNumber 0 is the first file.
+func (c *Client) ResolveEntry(ctx context.Context, jobID string, fileNumber, lineNumber uint64) (*ResolvedEntry, error) {
+	q := url.Values{"line": {strconv.FormatUint(lineNumber, 10)}}
+	if fileNumber > 0 {
+		q.Set("file", strconv.FormatUint(fileNumber, 10))
+	}
+	var resolved ResolvedEntry
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/entries/resolve", jobID), query: q}, &resolved); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// EntryContext returns the entries around an entry, window of them on each
+// side; zero takes the server's default.
+func (c *Client) EntryContext(ctx context.Context, jobID, entryID string, window int) (*ContextResponse, error) {
+	q := url.Values{}
+	if window > 0 {
+		q.Set("window", strconv.Itoa(window))
+	}
+	var resp ContextResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/entries/%s/context", jobID, entryID), query: q}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ExplainEntry explains an entry of a completed analysis.
+func (c *Client) ExplainEntry(ctx context.Context, jobID, entryID string) (*EntryExplanation, error) {
+	var explanation EntryExplanation
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/entries/%s/explain", jobID, entryID)}, &explanation); err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// Trace returns the entries of a trace, at most limit of them after
+// cursor, the NextCursor of the previous page. Zero limit and empty cursor
+// read from the start up to the server's cap.
+func (c *Client) Trace(ctx context.Context, jobID, traceID string, limit int, cursor string) (*Trace, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	setParam(q, "cursor", cursor)
+	var trace Trace
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/trace/%s", jobID, traceID), query: q}, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// Waterfall returns the spans of a trace, with its critical path when
+// criticalPath is set.
+func (c *Client) Waterfall(ctx context.Context, jobID, traceID string, criticalPath bool) (*WaterfallResponse, error) {
+	q := url.Values{}
+	if !criticalPath {
+		q.Set("include_critical_path", "false")
+	}
+	var resp WaterfallResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/trace/%s/waterfall", jobID, traceID), query: q}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ExportTrace returns the entries of a trace as a file, format "json" or
+// "csv".
+func (c *Client) ExportTrace(ctx context.Context, jobID, traceID, format string) ([]byte, error) {
+	req := &request{
+		method: http.MethodGet,
+		path:   pathf("/analysis/%s/trace/%s/export", jobID, traceID),
+		query:  url.Values{"format": {format}},
+	}
+	body, _, err := c.doRaw(ctx, req)
+	return body, err
+}
+
+// Transactions searches the transactions of a completed analysis.
+func (c *Client) Transactions(ctx context.Context, jobID string, params TransactionSearchParams) (*TransactionSearchResponse, error) {
+	q := url.Values{}
+	setParam(q, "user", params.User)
+	setParam(q, "thread_id", params.ThreadID)
+	setParam(q, "trace_id", params.TraceID)
+	setParam(q, "rpc_id", params.RPCID)
+	if params.HasErrors != nil {
+		q.Set("has_errors", strconv.FormatBool(*params.HasErrors))
+	}
+	if params.MinDuration > 0 {
+		q.Set("min_duration_ms", strconv.Itoa(params.MinDuration))
+	}
+	if params.Limit > 0 {
+		q.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		q.Set("offset", strconv.Itoa(params.Offset))
+	}
+	var resp TransactionSearchResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: pathf("/analysis/%s/transactions", jobID), query: q}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/analysis/j1/trace/t1", r.URL.Path)
+		assert.Equal(t, "cursor=abc&limit=2", r.URL.RawQuery)
+		// The streamed shape the handler writes: entries, then the trailer.
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"trace_id":"t1","entries":[{"id":"e1","fields":{"duration_ms":5}},
+{"id":"e2","fields":{"duration_ms":7}}],"entry_count":2,"total_duration":12,"truncated":true,"next_cursor":"def"}`))
+	}))
+
+	trace, err := c.Trace(context.Background(), "j1", "t1", 2, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "t1", trace.TraceID)
+	require.Len(t, trace.Entries, 2)
+	assert.Equal(t, "e2", trace.Entries[1].ID)
+	assert.Equal(t, 12, trace.TotalDuration)
+	assert.True(t, trace.Truncated)
+	assert.Equal(t, "def", trace.NextCursor)
+}
+
+func TestTraceEndpoints(t *testing.T) {
+	var path, query string
+	c, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		writeJSON(w, http.StatusOK, map[string]any{})
+	}))
+	ctx := context.Background()
+	hasErrors := true
+
+	tests := []struct {
+		call  func() error
+		path  string
+		query string
+	}{
+		{func() error { _, err := c.Entry(ctx, "j1", "e1"); return err }, "/api/v1/analysis/j1/entries/e1", ""},
+		{func() error { _, err := c.ResolveEntry(ctx, "j1", 2, 40); return err }, "/api/v1/analysis/j1/entries/resolve", "file=2&line=40"},
+		{func() error { _, err := c.EntryContext(ctx, "j1", "e1", 5); return err }, "/api/v1/analysis/j1/entries/e1/context", "window=5"},
+		{func() error { _, err := c.ExplainEntry(ctx, "j1", "e1"); return err }, "/api/v1/analysis/j1/entries/e1/explain", ""},
+		{func() error { _, err := c.Waterfall(ctx, "j1", "t1", true); return err }, "/api/v1/analysis/j1/trace/t1/waterfall", ""},
+		{func() error { _, err := c.Waterfall(ctx, "j1", "t1", false); return err }, "/api/v1/analysis/j1/trace/t1/waterfall", "include_critical_path=false"},
+		{func() error { _, err := c.ExportTrace(ctx, "j1", "t1", "csv"); return err }, "/api/v1/analysis/j1/trace/t1/export", "format=csv"},
+		{func() error {
+			_, err := c.Transactions(ctx, "j1", TransactionSearchParams{User: "Demo", HasErrors: &hasErrors, MinDuration: 100, Limit: 10, Offset: 20})
+			return err
+		}, "/api/v1/analysis/j1/transactions", "has_errors=true&limit=10&min_duration_ms=100&offset=20&user=Demo"},
+	}
+	for _, tt := range tests {
+		require.NoError(t, tt.call())
+		assert.Equal(t, tt.path, path)
+		assert.Equal(t, tt.query, query, tt.path)
+	}
+}
@@ -0,0 +1,356 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+)
+
+// The server's domain types, re-exported so that callers can name the
+// results of the client's methods. They are aliases: a response decodes
+// into the very type the server encoded it from.
+type (
+	LogFile                     = domain.LogFile
+	LogEntry                    = domain.LogEntry
+	LogSourceType               = domain.LogSourceType
+	AnalysisJob                 = domain.AnalysisJob
+	JobStatus                   = domain.JobStatus
+	JobPriority                 = domain.JobPriority
+	JobEstimate                 = domain.JobEstimate
+	JobTimelineEvent            = domain.JobTimelineEvent
+	JobQueueEstimate            = domain.JobQueueEstimate
+	JARFlags                    = domain.JARFlags
+	Investigation               = domain.Investigation
+	InvestigationStatus         = domain.InvestigationStatus
+	InvestigationEvent          = domain.InvestigationEvent
+	DashboardData               = domain.DashboardData
+	AggregatesResponse          = domain.AggregatesResponse
+	AggregatePage               = domain.AggregatePage
+	AggregateSort               = domain.AggregateSort
+	SortKey                     = domain.SortKey
+	ExceptionsResponse          = domain.ExceptionsResponse
+	GapsResponse                = domain.GapsResponse
+	ThreadStatsResponse         = domain.ThreadStatsResponse
+	FilterComplexityResponse    = domain.FilterComplexityResponse
+	JARAggregatesResponse       = domain.JARAggregatesResponse
+	JARExceptionsResponse       = domain.JARExceptionsResponse
+	JARGapsResponse             = domain.JARGapsResponse
+	JARThreadStatsResponse      = domain.JARThreadStatsResponse
+	JARFilterComplexityResponse = domain.JARFilterComplexityResponse
+	QueuedCallsResponse         = domain.QueuedCallsResponse
+	LoggingActivityResponse     = domain.LoggingActivityResponse
+	FileMetadataResponse        = domain.FileMetadataResponse
+	DelayedEscalationsResponse  = domain.DelayedEscalationsResponse
+	ErrorHeatmapResponse        = domain.ErrorHeatmapResponse
+	ThreadTimelineResponse      = domain.ThreadTimelineResponse
+	ErrorOnset                  = domain.ErrorOnset
+	SQLTableDrilldown           = domain.SQLTableDrilldown
+	APILegendResponse           = domain.APILegendResponse
+	HistogramBucket             = domain.HistogramBucket
+	SavedSearch                 = domain.SavedSearch
+	SearchHistoryEntry          = domain.SearchHistoryEntry
+	ContextResponse             = domain.ContextResponse
+	EntryExplanation            = domain.EntryExplanation
+	WaterfallResponse           = domain.WaterfallResponse
+	TransactionSearchParams     = domain.TransactionSearchParams
+	TransactionSearchResponse   = domain.TransactionSearchResponse
+	SearchExport                = domain.SearchExport
+	ExportStatus                = domain.ExportStatus
+	ArtifactProvenance          = domain.ArtifactProvenance
+	ProvenanceAnalysis          = domain.ProvenanceAnalysis
+	ProvenanceSummary           = domain.ProvenanceSummary
+	ThresholdRule               = domain.ThresholdRule
+	ThresholdScope              = domain.ThresholdScope
+	ThresholdMetric             = domain.ThresholdMetric
+	ThresholdComparator         = domain.ThresholdComparator
+	ThresholdSeverity           = domain.ThresholdSeverity
+	ThresholdViolation          = domain.ThresholdViolation
+	IngestionFilterRule         = domain.IngestionFilterRule
+	IngestionFilterOperator     = domain.IngestionFilterOperator
+	IngestionFilterAction       = domain.IngestionFilterAction
+	AnalysisLink                = domain.AnalysisLink
+	AnalysisLinkType            = domain.AnalysisLinkType
+)
+
+// The statuses of an analysis.
+const (
+	JobStatusQueued    = domain.JobStatusQueued
+	JobStatusParsing   = domain.JobStatusParsing
+	JobStatusAnalyzing = domain.JobStatusAnalyzing
+	JobStatusStoring   = domain.JobStatusStoring
+	JobStatusComplete  = domain.JobStatusComplete
+	JobStatusFailed    = domain.JobStatusFailed
+)
+
+// The types below mirror request and response bodies the server declares
+// outside the domain package. The handlers' contract test keeps their JSON
+// fields in step with the server's.
+
+// Pagination is the page of a listing.
+type Pagination struct {
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	TotalCount int `json:"total_count"`
+	TotalPages int `json:"total_pages"`
+}
+
+// Sampling asks for a sampled analysis: one in Rate entries is kept, and
+// every entry slower than SlowThresholdMS.
+type Sampling struct {
+	Rate            int    `json:"rate"`
+	SlowThresholdMS uint32 `json:"slow_threshold_ms,omitempty"`
+}
+
+// CreateAnalysisRequest is the body of POST /analysis.
+type CreateAnalysisRequest struct {
+	FileID           string      `json:"file_id"`
+	JARFlags         *JARFlags   `json:"jar_flags,omitempty"`
+	CorrectClockSkew bool        `json:"correct_clock_skew,omitempty"`
+	Priority         JobPriority `json:"priority,omitempty"`
+	Sampling         *Sampling   `json:"sampling,omitempty"`
+	// Sync asks for the analysis to run inline, or not; the server decides
+	// by the size of the file when nil.
+	Sync *bool `json:"sync,omitempty"`
+}
+
+// EstimateRequest is the body of POST /analyses/estimate: an uploaded file,
+// or the size and type of one not yet uploaded.
+type EstimateRequest struct {
+	FileID     string        `json:"file_id,omitempty"`
+	SizeBytes  int64         `json:"size_bytes,omitempty"`
+	SourceType LogSourceType `json:"source_type,omitempty"`
+	Priority   JobPriority   `json:"priority,omitempty"`
+	Sampling   *Sampling     `json:"sampling,omitempty"`
+}
+
+// AnalysisEvents is the timeline of an analysis.
+type AnalysisEvents struct {
+	JobID  string             `json:"job_id"`
+	Status JobStatus          `json:"status"`
+	Events []JobTimelineEvent `json:"events"`
+	// IdleMS is the time since the last event of a running analysis.
+	IdleMS *int64 `json:"idle_ms,omitempty"`
+}
+
+// InvestigationUpdate is the body of PATCH /analysis/{job_id}/investigation.
+// Nil fields are left unchanged; Version, when set, is the version the
+// update was made from, and a concurrent update fails it with a conflict.
+type InvestigationUpdate struct {
+	Version  *int                 `json:"version"`
+	Status   *InvestigationStatus `json:"status"`
+	Notes    *string              `json:"notes"`
+	Assignee *string              `json:"assignee"`
+}
+
+// InvestigationResponse is the investigation of an analysis after an
+// update.
+type InvestigationResponse struct {
+	JobID         string        `json:"job_id"`
+	Investigation Investigation `json:"investigation"`
+}
+
+// InvestigationHistory is the changes made to the investigation of an
+// analysis.
+type InvestigationHistory struct {
+	JobID  string               `json:"job_id"`
+	Events []InvestigationEvent `json:"events"`
+}
+
+// SearchQuery is a search of the entries of an analysis. It mirrors the
+// query the server runs, and is the query of an asynchronous export.
+type SearchQuery struct {
+	Query        string     `json:"query"`
+	LogTypes     []string   `json:"log_types,omitempty"`
+	TimeFrom     *time.Time `json:"time_from,omitempty"`
+	TimeTo       *time.Time `json:"time_to,omitempty"`
+	UserFilter   string     `json:"user_filter,omitempty"`
+	Users        []string   `json:"users,omitempty"`
+	QueueFilter  string     `json:"queue_filter,omitempty"`
+	Queues       []string   `json:"queues,omitempty"`
+	SortBy       string     `json:"sort_by,omitempty"`
+	SortOrder    string     `json:"sort_order,omitempty"`
+	Page         int        `json:"page"`
+	PageSize     int        `json:"page_size"`
+	IncludeNoise bool       `json:"include_noise,omitempty"`
+
+	// SecondarySortBy orders the hits that tie on SortBy.
+	SecondarySortBy    string `json:"secondary_sort_by,omitempty"`
+	SecondarySortOrder string `json:"secondary_sort_order,omitempty"`
+}
+
+// ComputedColumn is an expression evaluated for each search hit.
+type ComputedColumn struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// SearchOptions are the parts of a search beyond its query.
+type SearchOptions struct {
+	IncludeHistogram bool
+	// Suggest asks for refinements of the query.
+	Suggest  bool
+	Computed []ComputedColumn
+}
+
+// SearchResponse is a page of search hits.
+type SearchResponse struct {
+	Results             []SearchHit             `json:"results"`
+	Total               int                     `json:"total"`
+	Page                int                     `json:"page"`
+	PageSize            int                     `json:"page_size"`
+	TotalPages          int                     `json:"total_pages"`
+	Facets              map[string][]FacetEntry `json:"facets,omitempty"`
+	Histogram           []HistogramBucket       `json:"histogram,omitempty"`
+	TookMS              int                     `json:"took_ms"`
+	Suggestions         []Suggestion            `json:"suggestions,omitempty"`
+	QueryInterpretation *QueryInterpretation    `json:"query_interpretation,omitempty"`
+	TimeRange           *ResolvedTimeRange      `json:"time_range,omitempty"`
+	// Sort is the order of the hits, tiebreakers included.
+	Sort []SortKey `json:"sort"`
+}
+
+// SearchHit is one entry matching a search.
+type SearchHit struct {
+	ID       string         `json:"id"`
+	Score    float64        `json:"score"`
+	Fields   map[string]any `json:"fields"`
+	Computed map[string]any `json:"computed,omitempty"`
+}
+
+// FacetEntry is a value of a facet and the number of hits with it.
+type FacetEntry struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Suggestion is a refinement of a search and the hits it would leave.
+type Suggestion struct {
+	Field          string  `json:"field"`
+	KQL            string  `json:"kql"`
+	Query          string  `json:"query"` // the current query with KQL appended
+	PredictedCount int64   `json:"predicted_count"`
+	Share          float64 `json:"share"`
+	Reason         string  `json:"reason"`
+}
+
+// QueryInterpretation tells how the free text of a query matched.
+type QueryInterpretation struct {
+	Terms []InterpretedTerm `json:"terms"`
+	Notes []string          `json:"notes,omitempty"`
+}
+
+// InterpretedTerm is a free-text term of a query and how it matched.
+type InterpretedTerm struct {
+	Term  string `json:"term"`
+	Match string `json:"match"`
+}
+
+// ResolvedTimeRange echoes the time bounds of a request with the times they
+// resolved to.
+type ResolvedTimeRange struct {
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	FromExpr string     `json:"from_expr,omitempty"`
+	ToExpr   string     `json:"to_expr,omitempty"`
+}
+
+// SavedSearchRequest is the body of POST /search/saved.
+type SavedSearchRequest struct {
+	Name     string          `json:"name"`
+	KQLQuery string          `json:"kql_query"`
+	Filters  json.RawMessage `json:"filters,omitempty"`
+}
+
+// ResolvedEntry is the ID of the entry at a line of a file of an analysis.
+type ResolvedEntry struct {
+	JobID      string `json:"job_id"`
+	EntryID    string `json:"entry_id"`
+	FileNumber uint64 `json:"file_number"`
+	LineNumber uint64 `json:"line_number"`
+}
+
+// Trace is the entries of a trace, oldest first.
+type Trace struct {
+	TraceID       string       `json:"trace_id"`
+	Entries       []TraceEntry `json:"entries"`
+	EntryCount    int          `json:"entry_count"`
+	TotalDuration int          `json:"total_duration"`
+	// Truncated is set when the trace has more entries than were read;
+	// NextCursor then reads the next ones.
+	Truncated  bool   `json:"truncated"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Error is set when the trace failed after entries were sent.
+	Error string `json:"error,omitempty"`
+}
+
+// TraceEntry is one entry of a trace.
+type TraceEntry struct {
+	ID     string         `json:"id"`
+	Fields map[string]any `json:"fields"`
+}
+
+// CreateExportRequest is the body of POST /analysis/{job_id}/exports.
+type CreateExportRequest struct {
+	Format string      `json:"format"`
+	Query  SearchQuery `json:"query"`
+}
+
+// ThresholdRuleRequest is the body creating or replacing a threshold rule.
+type ThresholdRuleRequest struct {
+	Name       string              `json:"name"`
+	Scope      ThresholdScope      `json:"scope"`
+	ScopeValue string              `json:"scope_value"`
+	Metric     ThresholdMetric     `json:"metric"`
+	Comparator ThresholdComparator `json:"comparator"`
+	Value      float64             `json:"value"`
+	Severity   ThresholdSeverity   `json:"severity"`
+	Enabled    *bool               `json:"enabled"`
+}
+
+// Violations are the threshold rules an analysis violated.
+type Violations struct {
+	JobID string `json:"job_id"`
+	// Evaluated is false until the rules were evaluated for the analysis.
+	Evaluated  bool                 `json:"evaluated"`
+	Violations []ThresholdViolation `json:"violations"`
+	Total      int                  `json:"total"`
+}
+
+// IngestionFilterRequest is the body creating or replacing an ingestion
+// filter rule.
+type IngestionFilterRequest struct {
+	Name          string                  `json:"name"`
+	Field         string                  `json:"field"`
+	Operator      IngestionFilterOperator `json:"operator"`
+	Value         string                  `json:"value"`
+	CaseSensitive bool                    `json:"case_sensitive"`
+	Action        IngestionFilterAction   `json:"action"`
+	Enabled       *bool                   `json:"enabled"`
+}
+
+// DryRunRequest is the body of POST /ingestion-filters/dry-run.
+type DryRunRequest struct {
+	JobID string                   `json:"job_id"`
+	Rules []IngestionFilterRequest `json:"rules"`
+}
+
+// DryRunResponse is the entries of an analysis each rule would match.
+type DryRunResponse struct {
+	JobID        string         `json:"job_id"`
+	TotalEntries int64          `json:"total_entries"`
+	Results      []DryRunResult `json:"results"`
+}
+
+// DryRunResult is the entries one rule of a dry run matched.
+type DryRunResult struct {
+	Rule    IngestionFilterRule `json:"rule"`
+	Matched int64               `json:"matched"`
+}
+
+// AnalysisLinkRequest is the body of POST /analysis/{job_id}/links.
+type AnalysisLinkRequest struct {
+	Type        AnalysisLinkType `json:"type"`
+	ExternalKey string           `json:"external_key"`
+	Title       string           `json:"title"`
+}
@@ -0,0 +1,196 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/testutil"
+	"github.com/OmarEhab007/RemedyIQ/backend/pkg/client"
+)
+
+const (
+	// fixtureLog is the log of the golden job. It is an excerpt of the
+	// capture jar_output_log1 was recorded from.
+	fixtureLog = "ar25_sample.log"
+
+	fixtureTraceID = "oKNmA5MvSwOxCzBulz9-zQ:0002868"
+	fixtureTable   = "T4381"
+
+	jobTimeout = 2 * time.Minute
+	wsTimeout  = 10 * time.Second
+)
+
+// volatileKeys are the response fields besides testutil.VolatileKeys that
+// differ between runs of the suite.
+var volatileKeys = []string{"took_ms"}
+
+func golden(name string) string {
+	return filepath.Join("testdata", "golden", name+".golden.json")
+}
+
+// TestGoldenJob uploads the fixture log, has the worker analyse it and
+// checks the responses of the endpoints on the resulting job against the
+// golden files.
+func TestGoldenJob(t *testing.T) {
+	fileID := uploadFixture(t)
+	jobID := runAnalysis(t, fileID)
+
+	t.Run("pipeline", func(t *testing.T) {
+		calls := env.jar.Calls()
+		require.Len(t, calls, 1, "the JAR runs once per job")
+
+		// The progress is consumed from the tenant's NATS subject, and may
+		// trail the job row.
+		assert.Eventually(t, func() bool {
+			progress := env.jobProgress(jobID)
+			if len(progress) == 0 {
+				return false
+			}
+			last := progress[len(progress)-1]
+			return last.Status == string(domain.JobStatusComplete) && last.ProgressPct == 100
+		}, wsTimeout, 100*time.Millisecond, "no completion progress for the job on NATS")
+	})
+
+	base := "/analysis/" + jobID
+	endpoints := []struct {
+		name string
+		path string
+	}{
+		{"analysis", base},
+		{"analyses", "/analysis"},
+		{"files", "/files"},
+		{"dashboard", base + "/dashboard"},
+		{"aggregates", base + "/dashboard/aggregates"},
+		{"exceptions", base + "/dashboard/exceptions"},
+		{"error_heatmap", base + "/dashboard/exceptions/heatmap"},
+		{"gaps", base + "/dashboard/gaps"},
+		{"threads", base + "/dashboard/threads"},
+		{"filters", base + "/dashboard/filters"},
+		{"queued_calls", base + "/dashboard/queued-calls"},
+		{"logging_activity", base + "/dashboard/logging-activity"},
+		{"file_metadata", base + "/dashboard/file-metadata"},
+		{"delayed_escalations", base + "/dashboard/delayed-escalations"},
+		{"thread_timeline", "/analyses/" + jobID + "/threads/timeline"},
+		{"error_onset", "/analyses/" + jobID + "/error-onset"},
+		{"sql_table", "/analyses/" + jobID + "/sql/tables/" + fixtureTable},
+		{"search", base + "/search?q=*&sort_by=timestamp&sort_order=asc"},
+		{"transactions", base + "/transactions"},
+		{"trace", base + "/trace/" + url.PathEscape(fixtureTraceID)},
+		{"waterfall", base + "/trace/" + url.PathEscape(fixtureTraceID) + "/waterfall"},
+		{"legend", base + "/legend"},
+		{"violations", base + "/violations"},
+	}
+	for _, ep := range endpoints {
+		t.Run(ep.name, func(t *testing.T) {
+			resp, body := env.do(t, http.MethodGet, ep.path, nil, nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode, "GET %s: %s", ep.path, body)
+			testutil.AssertGolden(t, golden(ep.name), body, volatileKeys...)
+		})
+	}
+
+	t.Run("entry", func(t *testing.T) {
+		entryID := firstEntryID(t, jobID)
+
+		resp, body := env.do(t, http.MethodGet, base+"/entries/"+entryID, nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, "%s", body)
+		testutil.AssertGolden(t, golden("entry"), body, volatileKeys...)
+
+		resp, body = env.do(t, http.MethodGet, base+"/entries/"+entryID+"/context", nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, "%s", body)
+		testutil.AssertGolden(t, golden("entry_context"), body, volatileKeys...)
+	})
+
+	t.Run("section cache", func(t *testing.T) {
+		// The first read comes from the sections the pipeline cached, the
+		// second from Redis again: they must agree with each other and with
+		// the ETag.
+		resp, first := env.do(t, http.MethodGet, base+"/dashboard", nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		_, second := env.do(t, http.MethodGet, base+"/dashboard", nil, nil)
+		assert.JSONEq(t, string(first), string(second))
+
+		resp, _ = env.do(t, http.MethodGet, base+"/dashboard", nil, http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		ws := testutil.DialWS(t, env.server.URL+"/api/v1/ws", env.authHeader())
+		ws.Send("subscribe_investigations", nil)
+		ws.Sync(wsTimeout)
+
+		update := `{"version":0,"status":"investigating","notes":"Slow GetEntry on HPD:Help Desk"}`
+		resp, body := env.do(t, http.MethodPatch, base+"/investigation", bytes.NewBufferString(update),
+			http.Header{"Content-Type": {"application/json"}})
+		require.Equal(t, http.StatusOK, resp.StatusCode, "%s", body)
+		testutil.AssertGolden(t, golden("investigation_update"), body, volatileKeys...)
+
+		event := ws.Expect("investigation_updated", wsTimeout)
+		testutil.AssertGolden(t, golden("ws_investigation_updated"), event, volatileKeys...)
+
+		resp, body = env.do(t, http.MethodGet, base+"/investigation/history", nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, "%s", body)
+		testutil.AssertGolden(t, golden("investigation_history"), body, volatileKeys...)
+
+		ws.Send("subscribe_job_progress", map[string]string{})
+		testutil.AssertGolden(t, golden("ws_error_invalid_payload"), ws.Expect("error", wsTimeout))
+
+		ws.Send("no_such_message", nil)
+		testutil.AssertGolden(t, golden("ws_error_unknown_type"), ws.Expect("error", wsTimeout))
+	})
+}
+
+// uploadFixture uploads the fixture log and returns its file ID.
+func uploadFixture(t *testing.T) string {
+	t.Helper()
+	data := testutil.MustLoadFixture(t, fixtureLog)
+
+	file, err := env.client.UploadFile(context.Background(), fixtureLog, bytes.NewReader(data), "")
+	require.NoError(t, err, "upload")
+	body, err := json.Marshal(file)
+	require.NoError(t, err)
+	testutil.AssertGolden(t, golden("upload"), body, volatileKeys...)
+	return file.ID.String()
+}
+
+// runAnalysis submits the analysis of a file and waits for the worker to
+// complete it.
+func runAnalysis(t *testing.T, fileID string) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	job, err := env.client.CreateAnalysis(ctx, client.CreateAnalysisRequest{FileID: fileID})
+	require.NoError(t, err, "create analysis")
+	job, err = env.client.WaitForAnalysis(ctx, job.ID.String(), 250*time.Millisecond)
+	require.NoError(t, err, "analysis not complete after %s", jobTimeout)
+	return job.ID.String()
+}
+
+// firstEntryID returns the ID of the first log entry of the job.
+func firstEntryID(t *testing.T, jobID string) string {
+	t.Helper()
+	res, err := env.client.Search(context.Background(), jobID, client.SearchQuery{
+		Query:     "*",
+		SortBy:    "timestamp",
+		SortOrder: "asc",
+		PageSize:  1,
+	}, client.SearchOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Results, "the job has no entries")
+	return res.Results[0].ID
+}
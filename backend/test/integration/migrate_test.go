@@ -0,0 +1,95 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/domain"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/storage"
+	"github.com/OmarEhab007/RemedyIQ/backend/internal/tenantmigrate"
+)
+
+// defaultClickHouseTargetURL is the second ClickHouse server of
+// docker-compose.test.yml, which tenants are migrated to.
+const defaultClickHouseTargetURL = "clickhouse://localhost:59001/default"
+
+// TestTenantMigration moves a tenant to a second ClickHouse server, with a
+// chunk left half-copied on the target as by an interrupted run, and reads
+// its entries back through the route.
+func TestTenantMigration(t *testing.T) {
+	ctx := context.Background()
+	tenantID := uuid.New()
+	tenant := tenantID.String()
+	require.NoError(t, env.pg.CreateTenant(ctx, &domain.Tenant{
+		ID: tenantID, ClerkOrgID: "org_it_mig_" + env.runID, Name: "Migration " + env.runID,
+		Plan: "free", StorageLimitGB: 1,
+	}))
+
+	jobs := []string{uuid.NewString(), uuid.NewString()}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for _, jobID := range jobs {
+		entries := make([]domain.LogEntry, 250)
+		for i := range entries {
+			entries[i] = domain.LogEntry{
+				TenantID:   tenant,
+				JobID:      jobID,
+				EntryID:    fmt.Sprintf("e-%d", i),
+				LineNumber: uint32(i + 1),
+				FileNumber: 1,
+				Timestamp:  domain.NewTimestamp(base.Add(time.Duration(i) * time.Second)),
+				IngestedAt: domain.NewTimestamp(base),
+				LogType:    domain.LogTypeAPI,
+				TraceID:    fmt.Sprintf("trace-%d", i%7),
+				User:       "Demo",
+				DurationMS: uint32(i % 40),
+				Success:    i%11 != 0,
+				APICode:    "GetEntry",
+				Form:       "HPD:Help Desk",
+				RawText:    fmt.Sprintf("line %d", i+1),
+			}
+		}
+		require.NoError(t, env.ch.BatchInsertEntries(ctx, entries))
+	}
+
+	dsn, err := env.clickhouseDatabase(ctx, getEnv("CLICKHOUSE_TARGET_URL", defaultClickHouseTargetURL))
+	require.NoError(t, err)
+	require.NoError(t, env.ch.AddCluster(ctx, "target", dsn))
+	src, err := env.ch.Cluster(storage.DefaultClickHouseCluster)
+	require.NoError(t, err)
+	dst, err := env.ch.Cluster("target")
+	require.NoError(t, err)
+
+	// Half of the chunk [100, 200) of the first job, as an interrupted
+	// insert leaves it.
+	partial, err := src.ReadChunk(ctx, "log_entries", tenant, jobs[0], 100, 150)
+	require.NoError(t, err)
+	require.NoError(t, dst.InsertChunk(ctx, "log_entries", partial))
+
+	res, err := tenantmigrate.New(env.pg, src, dst, tenantmigrate.Options{ChunkLines: 100, VerifyJobs: 2}).
+		Run(ctx, tenantID, storage.DefaultClickHouseCluster, "target")
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Jobs)
+	assert.Equal(t, 6, res.ChunksCopied) // [0,100) [100,200) [200,300) per job
+	assert.Equal(t, int64(500), res.RowsCopied)
+	assert.Equal(t, 2, res.JobsVerified)
+
+	require.NoError(t, env.ch.RefreshRoutes(ctx, env.pg))
+	assert.Equal(t, "target", env.ch.Route(tenant))
+
+	// The routed reads land on the target, which the source would answer
+	// the same: drop the source rows to tell them apart.
+	for _, jobID := range jobs {
+		require.NoError(t, src.DeleteChunk(ctx, "log_entries", tenant, jobID, 0, 1<<32))
+		n, err := env.ch.CountJobEntries(storage.WithTenant(ctx, tenant), tenant, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(250), n)
+	}
+}
@@ -19,7 +19,8 @@ export type JobStatus =
   | "analyzing"
   | "storing"
   | "complete"
-  | "failed";
+  | "failed"
+  | "cancelled";
 
 // ---------------------------------------------------------------------------
 // Pagination
@@ -79,12 +80,60 @@ export interface AnalysisJob {
   created_at: string;
   updated_at?: string;
   completed_at: string | null;
+  // Set once a user asked for the job to be cancelled.
+  cancel_requested_at?: string | null;
   flags?: Record<string, string> | null;
+  // Which log types the capture holds; tabs of absent ones can be hidden.
+  sections?: SectionPresence | null;
+  // Entries each ingestion filter rule dropped or flagged as noise.
+  ingestion_filters?: IngestionFilterMatch[];
+  // Path of the job's event timeline; set on job_complete messages.
+  events_url?: string;
+  // Set when the job ingested a sample of its capture.
+  sampling?: Sampling;
+  // Linked Jira issues, ServiceNow records and URLs; set on list responses.
+  links?: AnalysisLink[];
+}
+
+export interface HotWindow {
+  start: string;
+  end: string;
+  reason: 'anomaly' | 'error_rate_crossing' | 'merged';
+}
+
+export interface Sampling {
+  rate: number;
+  slow_threshold_ms: number;
+  hot_windows?: HotWindow[];
+  sampled_entries: number;
+  full_fidelity_entries: number;
+  skipped_entries: number;
+}
+
+// Set on analytics of a sampled capture, whose counts are scaled estimates.
+export interface Estimate {
+  estimated?: boolean;
+  sample_rate?: number;
+}
+
+export interface LogTypePresence {
+  present: boolean;
+  jar_count: number;
+  ingested_count?: number;
+}
+
+export interface SectionPresence {
+  api: LogTypePresence;
+  sql: LogTypePresence;
+  filter: LogTypePresence;
+  escalation: LogTypePresence;
+  warnings?: string[];
 }
 
 export interface CreateAnalysisRequest {
   file_id: string;
   flags?: Record<string, string>;
+  sampling?: { rate: number; slow_threshold_ms?: number };
 }
 
 export interface ListAnalysesResponse {
@@ -108,6 +157,9 @@ export interface GeneralStatistics {
   log_start: string | null;
   log_end: string | null;
   log_duration: string | null;
+  log_duration_ms?: number;
+  /** Threads configured per queue; only v4 JAR output reports them. */
+  thread_counts?: Record<string, number>;
 }
 
 // ---------------------------------------------------------------------------
@@ -195,7 +247,7 @@ export interface Distribution {
 // Dashboard — aggregated response
 // ---------------------------------------------------------------------------
 
-export interface DashboardData {
+export interface DashboardData extends Estimate {
   general_stats: GeneralStatistics;
   top_api_calls: TopNEntry[];
   top_sql_statements: TopNEntry[];
@@ -204,6 +256,9 @@ export interface DashboardData {
   time_series: TimeSeriesPoint[];
   distribution: Distribution;
   health_score: HealthScore;
+  // Top-N tables ("api", "sql", "filters", "escalations") left empty because
+  // the capture holds no entries of their log type.
+  no_data?: string[];
 }
 
 // ---------------------------------------------------------------------------
@@ -226,7 +281,7 @@ export interface AggregateSection {
   groups: AggregateGroup[];
 }
 
-export interface AggregatesResponse {
+export interface AggregatesResponse extends Estimate {
   job_id: string;
   sections: AggregateSection[];
 }
@@ -271,7 +326,7 @@ export interface ExceptionEntry {
   duration_ms: number | null;
 }
 
-export interface ExceptionsResponse {
+export interface ExceptionsResponse extends Estimate {
   job_id: string;
   exceptions: ExceptionEntry[];
   total: number;
@@ -359,6 +414,26 @@ export interface ThreadStatsResponse {
   job_id: string;
   thread_stats: ThreadStatsEntry[];
   total_threads: number;
+  capacity?: ThreadCapacity;
+}
+
+export type CapacityVerdict = "healthy" | "near_capacity" | "saturated";
+
+export interface QueueCapacity {
+  queue: string;
+  configured_threads: number;
+  observed_threads: number;
+  /** Configured threads unknown; the observed ones stand in. */
+  estimated: boolean;
+  busy_pct: number;
+  peak_minute?: string;
+  peak_minute_busy_pct: number;
+  verdict: CapacityVerdict;
+}
+
+export interface ThreadCapacity {
+  queues: QueueCapacity[];
+  estimated: boolean;
 }
 
 // JAR-native thread stats
@@ -374,6 +449,7 @@ export interface JARThreadStatsResponse {
   job_id: string;
   stats: JARThreadStat[];
   generated_at: string;
+  capacity?: ThreadCapacity;
 }
 
 // ---------------------------------------------------------------------------
@@ -408,6 +484,17 @@ export interface FilterComplexityResponse {
   avg_filters_per_transaction: number;
   max_filters_per_transaction: number;
   filter_levels?: JARFilterLevelEntry[];
+  // Filter time against API time, for the job and by form.
+  time_share?: FilterTimeShare;
+  form_time_share?: FilterTimeShare[];
+}
+
+export interface FilterTimeShare {
+  form?: string;
+  filter_time_ms: number;
+  api_time_ms: number;
+  // Filter time as a percentage of API time; null without API time.
+  share_pct: number | null;
 }
 
 // JAR-native filter complexity types
@@ -480,6 +567,8 @@ export interface LogEntry {
   esc_name: string | null;
   raw_text: string;
   error_message: string | null;
+  /** Flagged by an ingestion filter rule; only returned with include_noise=true. */
+  is_noise?: boolean;
 }
 
 export interface LogEntryContext {
@@ -727,6 +816,173 @@ export interface AutocompleteResponse {
   suggestions: AutocompleteSuggestion[];
 }
 
+// ---------------------------------------------------------------------------
+// Ingestion filter rules
+// ---------------------------------------------------------------------------
+
+export type IngestionFilterOperator = "equals" | "prefix" | "suffix" | "contains";
+export type IngestionFilterAction = "drop" | "flag";
+
+export interface IngestionFilterRule {
+  id: string;
+  tenant_id: string;
+  name: string;
+  field: string;
+  operator: IngestionFilterOperator;
+  value: string;
+  case_sensitive: boolean;
+  action: IngestionFilterAction;
+  enabled: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface IngestionFilterMatch {
+  rule_id: string;
+  rule_name: string;
+  action: IngestionFilterAction;
+  matched: number;
+}
+
+export interface IngestionFilterDryRunResponse {
+  job_id: string;
+  total_entries: number;
+  results: { rule: IngestionFilterRule; matched: number }[];
+}
+
+// ---------------------------------------------------------------------------
+// Analysis links
+// ---------------------------------------------------------------------------
+
+export type AnalysisLinkType = "jira" | "servicenow" | "url";
+
+export interface AnalysisLink {
+  id: string;
+  tenant_id: string;
+  job_id: string;
+  type: AnalysisLinkType;
+  external_key: string;
+  title?: string;
+  // Where the link points; unset for records of a system without integration.
+  url?: string;
+  // Status in the external system as last synced, "unknown" when it could
+  // not be looked up.
+  status?: string;
+  summary?: string;
+  status_synced_at?: string;
+  created_by?: string;
+  created_at: string;
+}
+
+export interface TicketingIntegration {
+  tenant_id: string;
+  type: Exclude<AnalysisLinkType, "url">;
+  base_url: string;
+  username?: string;
+  enabled: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+// ---------------------------------------------------------------------------
+// Entry explanation
+// ---------------------------------------------------------------------------
+
+export interface EntryRef {
+  entry_id: string;
+  api_code?: string;
+  api_name?: string;
+  timestamp: string;
+  duration_ms: number;
+}
+
+export interface EntryExplanation {
+  entry: LogEntry;
+  api: { code: string; name: string; source: "jar_parsed" | "static" } | null;
+  error: { code: number; category: string; summary: string; hint?: string } | null;
+  trace: {
+    trace_id: string;
+    position: number;
+    trace_entries: number;
+    depth: number;
+    sibling_filters: number;
+    preceding_api?: EntryRef;
+  } | null;
+  duration: {
+    scope: "form" | "sql_table";
+    value: string;
+    log_type: LogType;
+    duration_ms: number;
+    percentile: number;
+    samples: number;
+    p50_ms: number;
+    p95_ms: number;
+    p99_ms: number;
+    summary?: string;
+  } | null;
+  /** Why a section is null, keyed by section name. */
+  unavailable?: Partial<Record<"api" | "error" | "trace" | "duration", string>>;
+}
+
+// ---------------------------------------------------------------------------
+// In-flight queries
+// ---------------------------------------------------------------------------
+
+/** A running search or analytics request; `id` is its X-Query-ID header. */
+export interface InFlightQuery {
+  id: string;
+  tenant_id: string;
+  job_id?: string;
+  user_id?: string;
+  method: string;
+  path: string;
+  started_at: string;
+}
+
+export interface InFlightQueriesResponse {
+  job_id: string;
+  queries: InFlightQuery[];
+}
+
+// ---------------------------------------------------------------------------
+// Job event log
+// ---------------------------------------------------------------------------
+
+export type JobEventKind =
+  | "created"
+  | "stage_started"
+  | "stage_finished"
+  | "progress"
+  | "retry"
+  | "publish"
+  | "warning"
+  | "completed"
+  | "failed"
+  | "events_dropped";
+
+/** One event of a job's log, with the time since the previous and first event. */
+export interface JobTimelineEvent {
+  id: number;
+  tenant_id: string;
+  job_id: string;
+  source: "api" | "worker";
+  kind: JobEventKind;
+  stage?: string;
+  message?: string;
+  metadata?: Record<string, unknown>;
+  occurred_at: string;
+  since_previous_ms: number;
+  since_start_ms: number;
+}
+
+export interface JobEventsResponse {
+  job_id: string;
+  status: JobStatus;
+  events: JobTimelineEvent[];
+  // Time since the last event; only for jobs still running.
+  idle_ms?: number;
+}
+
 // ---------------------------------------------------------------------------
 // Search — saved searches
 // ---------------------------------------------------------------------------
@@ -804,6 +1060,12 @@ export interface HealthResponse {
   status: "ok" | "degraded" | "down";
   version: string;
   services: Record<string, "ok" | "error">;
+  coalescing?: CoalescingStats;
+}
+
+export interface CoalescingStats {
+  computed: number;
+  coalesced: number;
 }
 
 // ---------------------------------------------------------------------------
@@ -874,3 +1136,24 @@ export interface FileMetadataResponse {
   files: FileMetadataEntry[];
   total: number;
 }
+
+// ---------------------------------------------------------------------------
+// Tenant usage
+// ---------------------------------------------------------------------------
+
+export interface UsageMonth {
+  month: string; // YYYY-MM
+  bytes_uploaded: number;
+  analyses_run: number;
+  rows_stored: number;
+  worker_ms: number;
+  ai_queries: number;
+}
+
+export interface TenantUsage {
+  tenant_id?: string; // absent for the all-tenants total
+  from: string;
+  to: string;
+  months: UsageMonth[];
+  month_to_date: UsageMonth;
+}
@@ -10,6 +10,8 @@
 
 import type {
   AnalysisJob,
+  AnalysisLink,
+  AnalysisLinkType,
   AggregatesResponse,
   AIQueryResponse,
   AISkill,
@@ -326,6 +328,46 @@ export async function getAnalysis(
   return apiFetch<AnalysisJob>(`/analysis/${encodeURIComponent(jobId)}`, {}, token);
 }
 
+/** GET /analysis/{job_id}/links */
+export async function listAnalysisLinks(
+  jobId: string,
+  token?: string,
+): Promise<{ links: AnalysisLink[] }> {
+  return apiFetch<{ links: AnalysisLink[] }>(
+    `/analysis/${encodeURIComponent(jobId)}/links`,
+    {},
+    token,
+  );
+}
+
+/** POST /analysis/{job_id}/links */
+export async function createAnalysisLink(
+  jobId: string,
+  type: AnalysisLinkType,
+  externalKey: string,
+  title?: string,
+  token?: string,
+): Promise<AnalysisLink> {
+  return apiFetch<AnalysisLink>(
+    `/analysis/${encodeURIComponent(jobId)}/links`,
+    { method: "POST", body: JSON.stringify({ type, external_key: externalKey, title }) },
+    token,
+  );
+}
+
+/** DELETE /analysis/{job_id}/links/{link_id} */
+export async function deleteAnalysisLink(
+  jobId: string,
+  linkId: string,
+  token?: string,
+): Promise<void> {
+  await apiFetch<void>(
+    `/analysis/${encodeURIComponent(jobId)}/links/${encodeURIComponent(linkId)}`,
+    { method: "DELETE" },
+    token,
+  );
+}
+
 // ---------------------------------------------------------------------------
 // Dashboard
 // ---------------------------------------------------------------------------
@@ -559,6 +601,34 @@ export async function exportSearchResults(
   return response.blob();
 }
 
+/**
+ * GET /analysis/{job_id}/dashboard/{section}?format= — one table of a
+ * dashboard section as a spreadsheet. Returns raw Blob.
+ *
+ * @param table - JSON path of the table, e.g. "api.groups"; the first with rows by default
+ */
+export async function exportSectionTable(
+  jobId: string,
+  section: "aggregates" | "exceptions" | "gaps" | "threads" | "filters" | "queued-calls",
+  format: "csv" | "xlsx" = "csv",
+  table?: string,
+  token?: string,
+): Promise<Blob> {
+  const qs = toQueryString({ format, table });
+  const url = `${API_BASE}/analysis/${encodeURIComponent(jobId)}/dashboard/${section}${qs}`;
+
+  const headers: Record<string, string> = {
+    ...getAuthHeaders(),
+    ...bearerHeader(token),
+  };
+
+  const response = await fetch(url, { headers });
+  if (!response.ok) {
+    throw new ApiError(response.status, "EXPORT_ERROR", response.statusText);
+  }
+  return response.blob();
+}
+
 // ---------------------------------------------------------------------------
 // Log entries
 // ---------------------------------------------------------------------------
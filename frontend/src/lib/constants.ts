@@ -99,7 +99,7 @@ export const LOG_LEVEL_CONFIG: Record<LogLevel, LogLevelConfig> = {
 
 // ---------------------------------------------------------------------------
 // Job status configuration
-// Matches JobStatus from api-types: queued | parsing | analyzing | storing | complete | failed
+// Matches JobStatus from api-types: queued | parsing | analyzing | storing | complete | failed | cancelled
 // ---------------------------------------------------------------------------
 
 export interface JobStatusConfig {
@@ -146,6 +146,12 @@ export const JOB_STATUS_CONFIG: Record<JobStatus, JobStatusConfig> = {
     label: 'Failed',
     description: 'Analysis encountered an error',
   },
+  cancelled: {
+    color: 'var(--color-warning)',
+    bgColor: 'var(--color-warning-light)',
+    label: 'Cancelled',
+    description: 'Analysis cancelled by a user',
+  },
 } as const
 
 // ---------------------------------------------------------------------------